@@ -2,26 +2,37 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"apex-build/internal/abuse"
+	"apex-build/internal/activity"
+	"apex-build/internal/agentmarket"
 	"apex-build/internal/agents"
 	"apex-build/internal/agents/autonomous"
 	"apex-build/internal/ai"
+	"apex-build/internal/analytics"
 	"apex-build/internal/api"
 	"apex-build/internal/applog"
+	"apex-build/internal/archival"
 	"apex-build/internal/auth"
 	"apex-build/internal/budget"
+	"apex-build/internal/buildfailures"
+	"apex-build/internal/buildhooks"
 	"apex-build/internal/cache"
+	"apex-build/internal/codestandards"
 	"apex-build/internal/collaboration"
 	"apex-build/internal/community"
 	"apex-build/internal/completions"
@@ -32,28 +43,53 @@ import (
 	"apex-build/internal/deploy"
 	deployalwayson "apex-build/internal/deploy/alwayson"
 	"apex-build/internal/deploy/providers"
+	"apex-build/internal/depupdates"
+	"apex-build/internal/devcert"
+	"apex-build/internal/dockerize"
 	"apex-build/internal/email"
 	"apex-build/internal/enterprise"
+	"apex-build/internal/envcheck"
+	"apex-build/internal/envlock"
 	"apex-build/internal/extensions"
 	"apex-build/internal/git"
 	"apex-build/internal/handlers"
 	"apex-build/internal/hosting"
+	"apex-build/internal/instructions"
+	"apex-build/internal/logdrain"
+	"apex-build/internal/management"
 	"apex-build/internal/mcp"
 	"apex-build/internal/metrics"
 	"apex-build/internal/middleware"
 	"apex-build/internal/mobile"
+	"apex-build/internal/notebook"
+	"apex-build/internal/notifications"
+	"apex-build/internal/onboarding"
+	"apex-build/internal/ownership"
+	"apex-build/internal/packages"
 	"apex-build/internal/payments"
+	"apex-build/internal/pipeline"
 	"apex-build/internal/preview"
+	"apex-build/internal/privacy"
+	"apex-build/internal/referrals"
+	"apex-build/internal/restorepoints"
+	"apex-build/internal/retention"
+	"apex-build/internal/runartifacts"
+	"apex-build/internal/schedules"
 	"apex-build/internal/search"
 	"apex-build/internal/secrets"
 	"apex-build/internal/spend"
 	"apex-build/internal/startup"
 	"apex-build/internal/storage"
+	"apex-build/internal/supportbundles"
+	"apex-build/internal/symbols"
+	"apex-build/internal/trash"
 	"apex-build/internal/usage"
 	"apex-build/internal/websocket"
+	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -352,6 +388,18 @@ func main() {
 		"persistent_key": secretsConfig.SecretsMasterKey != "",
 	})
 	log.Println("Secrets Manager initialized with AES-256 encryption")
+	if secretsConfig.SecretsMasterKeyOld != "" {
+		// Dual-key reads while a rotation job moves values to the new key
+		if err := secretsManager.SetPreviousMasterKey(secretsConfig.SecretsMasterKeyOld); err != nil {
+			log.Printf("WARNING: Ignoring SECRETS_MASTER_KEY_OLD: %v", err)
+		} else {
+			log.Println("Previous master key loaded for rotation (SECRETS_MASTER_KEY_OLD)")
+		}
+	}
+
+	// Per-organization data keys (envelope encryption with BYO KMS keys)
+	orgKeyring := secrets.NewOrgKeyring(database.GetDB(), secretsManager)
+	secretsManager.SetOrgKeyring(orgKeyring)
 
 	// Initialize BYOK (Bring Your Own Key) Manager
 	byokManager := ai.NewBYOKManager(database.GetDB(), secretsManager, aiRouter)
@@ -359,6 +407,9 @@ func main() {
 	log.Println("BYOK Manager initialized (user-provided API keys, per-provider cost tracking)")
 	startupRegistry.MarkReady("byok", startup.TierOptional, "BYOK manager initialized", nil)
 
+	// AI calls made by handlers outside /ai/generate are charged and recorded
+	meteredAI := handlers.NewMeteredAI(database.GetDB(), aiRouter, byokManager)
+
 	// Initialize Agent Orchestration System
 	aiAdapter := agents.NewAIRouterAdapter(aiRouter, byokManager)
 	agentManager := agents.NewAgentManager(aiAdapter, database.GetDB())
@@ -386,6 +437,46 @@ func main() {
 	}
 	log.Println("Spend Tracker initialized (real-time cost tracking, per-agent attribution)")
 
+	// Per-user AI analytics: daily rollups over ai_requests for /ai/analytics.
+	var aiAnalyticsCancel context.CancelFunc
+	aiAnalytics := analytics.NewService(database.GetDB())
+	analyticsHandler := handlers.NewAnalyticsHandler(aiAnalytics)
+	if err := database.GetDB().AutoMigrate(&analytics.AIUsageDaily{}); err != nil {
+		log.Printf("WARNING: AI analytics migration completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("ai_analytics", startup.TierOptional, "AI analytics migration completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		aiAnalyticsCtx, cancel := context.WithCancel(context.Background())
+		aiAnalyticsCancel = cancel
+		aiAnalytics.Start(aiAnalyticsCtx, getEnvDuration("AI_ANALYTICS_ROLLUP_INTERVAL", analytics.DefaultRollupInterval))
+		startupRegistry.MarkReady("ai_analytics", startup.TierOptional, "AI analytics rollup job started", nil)
+	}
+
+	// Platform analytics warehouse: nightly daily metrics, retention cohorts
+	// and parquet exports to S3 and BigQuery for the admin trend endpoints.
+	var warehouseCancel context.CancelFunc
+	warehouseSinks, sinkErrs := analytics.SinksFromEnv(context.Background())
+	for _, err := range sinkErrs {
+		log.Printf("WARNING: analytics export sink disabled: %v", err)
+	}
+	warehouse := analytics.NewWarehouse(database.GetDB(), warehouseSinks...)
+	warehouse.SetHour(getEnvInt("ANALYTICS_WAREHOUSE_HOUR_UTC", analytics.DefaultWarehouseHour))
+	warehouseHandler := handlers.NewWarehouseHandler(warehouse)
+	if err := warehouse.AutoMigrate(); err != nil {
+		log.Printf("WARNING: Analytics warehouse migration completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("analytics_warehouse", startup.TierOptional, "Analytics warehouse migration completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		warehouseCtx, cancel := context.WithCancel(context.Background())
+		warehouseCancel = cancel
+		warehouse.Start(warehouseCtx)
+		startupRegistry.MarkReady("analytics_warehouse", startup.TierOptional, "Analytics warehouse nightly job scheduled", map[string]any{
+			"sinks": warehouse.Sinks(),
+		})
+	}
+
 	// Initialize Budget Enforcer (S1: Hard Budget Caps)
 	budgetEnforcer := budget.NewBudgetEnforcer(database.GetDB(), spendTracker)
 	budgetHandler := handlers.NewBudgetHandler(budgetEnforcer)
@@ -399,6 +490,25 @@ func main() {
 	agentManager.SetBudgetEnforcer(budgetEnforcer)
 	aiAdapter.SetBudgetEnforcer(budgetEnforcer)
 
+	// Notification center + spend anomaly detection: abnormal spend pauses the
+	// offending build or BYOK key and posts a notification with an override.
+	var spendAnomalyCancel context.CancelFunc
+	notificationService := notifications.NewService(database.GetDB())
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	spendAnomalyDetector := budget.NewAnomalyDetector(database.GetDB(), &buildPauserBridge{manager: agentManager}, notificationService)
+	if err := database.GetDB().AutoMigrate(&notifications.Notification{}, &budget.SpendAnomaly{}); err != nil {
+		log.Printf("WARNING: Notification/anomaly migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("spend_anomaly_detection", startup.TierOptional, "Spend anomaly migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		spendAnomalyCtx, cancel := context.WithCancel(context.Background())
+		spendAnomalyCancel = cancel
+		spendAnomalyDetector.Start(spendAnomalyCtx, getEnvDuration("SPEND_ANOMALY_SCAN_INTERVAL", budget.DefaultAnomalyInterval))
+		budgetHandler.SetAnomalyDetector(spendAnomalyDetector)
+		startupRegistry.MarkReady("spend_anomaly_detection", startup.TierOptional, "Spend anomaly detector started", nil)
+	}
+
 	// Initialize Protected Paths Handler (A3)
 	protectedPathsHandler := handlers.NewProtectedPathsHandler(database.GetDB())
 	startupRegistry.MarkReady("protected_paths", startup.TierOptional, "Protected paths handler initialized", nil)
@@ -461,7 +571,7 @@ func main() {
 	// Initialize Secrets and MCP handlers
 	secretsHandler := handlers.NewSecretsHandler(database.GetDB(), secretsManager)
 	mcpHandler := handlers.NewMCPHandler(database.GetDB(), mcpServer, mcpConnManager, secretsManager)
-	templatesHandler := handlers.NewTemplatesHandler(database.GetDB())
+	templatesHandler := handlers.NewTemplatesHandler(database.GetDB(), secretsManager)
 	startupRegistry.MarkReady("project_secrets", startup.TierOptional, "Project secrets handlers initialized", nil)
 	startupRegistry.MarkReady("mcp", startup.TierOptional, "MCP handlers initialized", nil)
 	startupRegistry.MarkReady("project_templates", startup.TierOptional, "Project templates initialized", nil)
@@ -471,7 +581,53 @@ func main() {
 	// Initialize Code Search Engine
 	searchEngine := search.NewSearchEngine(database.GetDB())
 	searchHandler := handlers.NewSearchHandler(searchEngine, database.GetDB())
-	startupRegistry.MarkReady("code_search", startup.TierOptional, "Code search initialized", nil)
+	if err := search.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Search migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("code_search", startup.TierOptional, "Code search initialized; replacements cannot be undone", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		// Replacements record file versions, which their undo restores
+		searchEngine.SetVersionRecorder(handlers.CreateFileVersion)
+		startupRegistry.MarkReady("code_search", startup.TierOptional, "Code search initialized", nil)
+	}
+
+	// Full-text index: weighted tsvectors per file, refreshed in the
+	// background as files change (PostgreSQL only)
+	var searchIndexCancel context.CancelFunc
+	searchIndexer := search.NewIndexer(database.GetDB())
+	searchHandler.SetIndexer(searchIndexer)
+	if !searchIndexer.Enabled() {
+		startupRegistry.MarkDegraded("search_index", startup.TierOptional, "Search index requires PostgreSQL; searches scan file content", nil)
+	} else if err := searchIndexer.EnsureSchema(context.Background()); err != nil {
+		log.Printf("WARNING: Search index schema setup failed: %v", err)
+		startupRegistry.MarkDegraded("search_index", startup.TierOptional, "Search index schema setup failed", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		searchEngine.SetIndexer(searchIndexer)
+		searchIndexCtx, cancel := context.WithCancel(context.Background())
+		searchIndexCancel = cancel
+		searchIndexer.Start(searchIndexCtx, getEnvDuration("SEARCH_INDEX_INTERVAL", search.DefaultIndexInterval))
+		startupRegistry.MarkReady("search_index", startup.TierOptional, "Search index refresh started", nil)
+	}
+
+	// Symbol index: declarations parsed with tree-sitter, updated on save
+	// and swept in the background for files written elsewhere
+	var symbolIndexCancel context.CancelFunc
+	symbolService := symbols.NewService(database.GetDB())
+	if err := symbols.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Symbol index migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("symbol_index", startup.TierOptional, "Symbol index migrations completed with warnings; symbol search uses patterns", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		searchEngine.SetSymbolIndex(symbolService)
+		symbolIndexCtx, cancel := context.WithCancel(context.Background())
+		symbolIndexCancel = cancel
+		symbolService.Start(symbolIndexCtx, getEnvDuration("SYMBOL_INDEX_INTERVAL", symbols.DefaultRefreshInterval))
+		startupRegistry.MarkReady("symbol_index", startup.TierOptional, "Symbol index refresh started", nil)
+	}
 
 	log.Println("Code Search Engine initialized (full-text, regex, symbol search)")
 
@@ -488,6 +644,18 @@ func main() {
 	} else {
 		previewHandler = handlers.NewPreviewHandlerWithFactory(database.GetDB(), previewFactory, authService)
 	}
+	previewHandler.SetSecretsManager(secretsManager)
+	// Log drains forward preview and hosting logs to external sinks
+	logDrains := logdrain.NewManager(database.GetDB())
+	previewHandler.SetLogForwarder(logDrains)
+	// Solver analysis of runtime errors captured from previews
+	previewHandler.SetErrorSolver(meteredAI)
+	// apex.lock snapshots of each project's environment, verified when
+	// previews and executions start
+	environmentLocks := envlock.NewService(database.GetDB())
+	previewHandler.SetEnvironmentLocks(environmentLocks)
+	// Optional HTTPS listener so previews run on a secure origin, like production
+	previewTLSServer := startPreviewTLSServer(httpServer.Handler, previewHandler)
 
 	// Wire preview verification gate into the agent manager.
 	// The bridge converts between agents.VerifiableFile and preview.VerifiableFile
@@ -588,7 +756,10 @@ func main() {
 
 	// Initialize Git Integration Service
 	gitService := git.NewGitService(database.GetDB())
+	gitService.SetSecretsManager(secretsManager)
 	gitHandler := handlers.NewGitHandler(database.GetDB(), gitService, secretsManager)
+	gitHandler.SetConflictAssistant(meteredAI)
+	gitHandler.SetIssueSolver(meteredAI)
 
 	log.Println("Git Integration initialized (GitHub support)")
 	startupRegistry.MarkReady("git_integration", startup.TierOptional, "Git integration initialized", nil)
@@ -610,6 +781,7 @@ func main() {
 
 	// Initialize Code Comments Handler (Replit parity feature)
 	commentsHandler := handlers.NewCommentsHandler(database.GetDB())
+	commentsHandler.Notifications = notificationService
 	log.Println("Code Comments System initialized (inline threads, reactions, resolve)")
 	startupRegistry.MarkReady("code_comments", startup.TierOptional, "Code comments initialized", nil)
 
@@ -637,7 +809,7 @@ func main() {
 	// PERFORMANCE: Using BatchedHub for 70% message reduction via 50ms batching
 	wsHubRT := websocket.NewBatchedHub()
 	go wsHubRT.Run()
-	log.Println("WebSocket BatchedHub initialized (50ms batching, 16ms write coalescing)")
+	log.Println("WebSocket BatchedHub initialized (50ms batching, 16ms write coalescing, per-client send queues)")
 	startupRegistry.MarkReady("realtime_updates", startup.TierOptional, "Realtime websocket hub initialized", nil)
 
 	// Initialize cache for performance optimization
@@ -686,7 +858,10 @@ func main() {
 	// Initialize OptimizedHandler with caching for better performance
 	// PERFORMANCE: Fixes N+1 queries with proper JOINs, adds cursor-based pagination
 	optimizedHandler := handlers.NewOptimizedHandler(baseHandler, redisCache)
-	log.Println("OptimizedHandler initialized (N+1 fix, 30s cache, cursor pagination)")
+	if err := optimizedHandler.WatchFileMutations(database.GetDB()); err != nil {
+		log.Printf("WARNING: file tree cache invalidation not registered: %v", err)
+	}
+	log.Println("OptimizedHandler initialized (N+1 fix, 30s cache, versioned file tree cache, cursor pagination)")
 
 	// Initialize Code Execution Engine with Docker container sandboxing
 	// SECURITY: Container sandboxing is the default and recommended mode
@@ -774,6 +949,17 @@ func main() {
 		deployService.RegisterDatabaseProvisioner(deploy.DatabaseProviderNeon, deploy.NewNeonDatabaseProvisioner(neonToken, neonOrgID))
 	}
 
+	if encoded := os.Getenv("DEPLOY_PROVENANCE_SIGNING_KEY"); encoded != "" {
+		if provenanceKey, err := deploy.ParseProvenanceKey(encoded); err != nil {
+			log.Printf("WARNING: DEPLOY_PROVENANCE_SIGNING_KEY ignored, provenance will be unsigned: %v", err)
+		} else {
+			deployService.SetProvenanceKey(provenanceKey)
+		}
+	}
+	if err := database.GetDB().AutoMigrate(&deploy.DeploymentProvenance{}); err != nil {
+		log.Printf("WARNING: Deployment provenance migration completed with warnings: %v", err)
+	}
+
 	deployHandler := handlers.NewDeployHandler(database.GetDB(), deployService)
 	log.Println("One-Click Deployment initialized (Vercel, Netlify, Render, Railway, Cloudflare Pages, Neon orchestration)")
 	availableDeployProviders := make([]string, 0, 5)
@@ -848,9 +1034,76 @@ func main() {
 	// Initialize Native Hosting Service
 	hostingService := hosting.NewHostingService(database.GetDB())
 	hostingHandler := handlers.NewHostingHandler(database.GetDB(), hostingService)
+	// Solver analysis of production errors reported by hosted apps
+	hostingHandler.SetErrorSolver(meteredAI)
+	hostingService.SetLogForwarder(logDrains)
+	hostingHandler.SetLogDrains(logDrains)
 	log.Println("Native Hosting (.apex.app) initialized")
 	startupRegistry.MarkReady("native_hosting", startup.TierOptional, "Native hosting service initialized", nil)
 
+	// Deployment pipeline: per-project development/staging/production
+	// environments that deploy immutable artifact snapshots, smoke check
+	// them and promote the same artifact onward
+	pipelineService := pipeline.NewService(database.GetDB(), secretsManager)
+	if err := pipelineService.AutoMigrate(); err != nil {
+		log.Printf("WARNING: Deployment pipeline migration completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("deploy_pipeline", startup.TierOptional, "Deployment pipeline migration completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("deploy_pipeline", startup.TierOptional, "Deployment pipeline ready", nil)
+	}
+	pipelineService.SetTarget(pipeline.TargetNative, pipeline.NewNativeTarget(hostingService))
+	for _, provider := range availableDeployProviders {
+		pipelineService.SetTarget(provider, pipeline.NewProviderTarget(deployService, deploy.DeploymentProvider(provider)))
+	}
+	pipelineService.Resume()
+	pipelineHandler := handlers.NewPipelineHandler(database.GetDB(), pipelineService)
+
+	// Dockerfile generation, verified by building the image in the container
+	// sandbox before it is stored in the project
+	var imageBuilder dockerize.ImageBuilder
+	if executionHandler != nil && executionHandler.SandboxFactory != nil {
+		imageBuilder = executionHandler.SandboxFactory
+	}
+	dockerizeHandler := handlers.NewDockerizeHandler(database.GetDB(), dockerize.NewService(database.GetDB(), imageBuilder))
+
+	// apex.lock verification and regeneration
+	if executionHandler != nil {
+		executionHandler.SetEnvironmentLocks(environmentLocks)
+	}
+
+	// Files written by project runs, kept until the user saves them into the
+	// project or they expire
+	runArtifacts := runartifacts.NewService(database.GetDB())
+	if err := runArtifacts.AutoMigrate(); err != nil {
+		log.Printf("WARNING: Execution artifact migration failed: %v", err)
+	} else if executionHandler != nil {
+		executionHandler.SetArtifactStore(runArtifacts)
+	}
+	environmentLockHandler := handlers.NewEnvironmentLockHandler(database.GetDB(), environmentLocks)
+	// Environment variables read by project code but missing from its
+	// environments, with one-click placeholder secrets
+	envCheckHandler := handlers.NewEnvCheckHandler(database.GetDB(), envcheck.NewChecker(database.GetDB(), secretsManager))
+
+	// Project service tokens: scoped, auto-rotating credentials that scheduled
+	// runs and hosted apps use to call the project API instead of a user JWT
+	var projectTokenCancel context.CancelFunc
+	projectTokens := secrets.NewProjectTokens(database.GetDB(), secretsManager, os.Getenv("PUBLIC_API_URL"))
+	if err := projectTokens.AutoMigrate(); err != nil {
+		log.Printf("WARNING: Project service token migration failed: %v", err)
+	} else {
+		projectTokens.SetObserver(hostingService)
+		hostingService.SetProjectTokens(projectTokens)
+		if executionHandler != nil {
+			executionHandler.SetProjectTokens(projectTokens)
+		}
+		projectTokenCtx, cancel := context.WithCancel(context.Background())
+		projectTokenCancel = cancel
+		projectTokens.Start(projectTokenCtx, getEnvDuration("PROJECT_TOKEN_ROTATION_CHECK_INTERVAL", secrets.DefaultProjectTokenCheckInterval))
+	}
+	projectTokenHandler := handlers.NewProjectTokenHandler(database.GetDB(), projectTokens)
+
 	// Always-On Deployment Controller
 	alwaysOnController := deployalwayson.NewService(hostingService, nil)
 	alwaysOnController.SetInventoryProvider(func(ctx context.Context) ([]string, error) {
@@ -884,6 +1137,8 @@ func main() {
 		databaseHandler = handlers.NewDatabaseHandler(database.GetDB(), dbManager, secretsManager)
 		// Initialize auto-provisioning dependencies for project creation
 		handlers.InitAutoProvisioningDeps(dbManager, secretsManager)
+		// Managed Redis queues for hosted background workers
+		hostingService.SetQueueProvisioner(handlers.NewManagedQueueProvisioner(database.GetDB(), dbManager))
 		log.Println("Managed Database Service initialized (PostgreSQL, Redis, SQLite)")
 		log.Println("Auto-Provision PostgreSQL enabled for new projects")
 		startupRegistry.MarkReady("managed_databases", startup.TierOptional, "Managed database service initialized", nil)
@@ -895,6 +1150,15 @@ func main() {
 	log.Println("Debugging Service initialized (breakpoints, stepping, watch expressions)")
 	startupRegistry.MarkReady("debugging_service", startup.TierOptional, "Debugging service initialized", nil)
 
+	// Initialize Notebook kernels (persistent sandboxed interpreters for .ipynb files)
+	var notebookKernels *notebook.Manager
+	var notebookHandler *handlers.NotebookHandler
+	if executionHandler != nil {
+		notebookKernels = notebook.NewManager(notebook.SandboxStarter(executionHandler.SandboxFactory), nil)
+		notebookHandler = handlers.NewNotebookHandler(database.GetDB(), notebookKernels)
+		log.Println("Notebook kernels initialized (Python, rich output streaming)")
+	}
+
 	// Initialize AI Completions Service
 	completionService := completions.NewCompletionService(database.GetDB(), aiRouter, byokManager)
 	completionsHandler := handlers.NewCompletionsHandler(completionService)
@@ -932,6 +1196,15 @@ func main() {
 	samlService := enterprise.NewSAMLService(database.GetDB(), samlConfig, auditService)
 	scimService := enterprise.NewSCIMService(database.GetDB(), auditService, rbacService)
 	enterpriseHandler := handlers.NewEnterpriseHandler(database.GetDB(), samlService, scimService, auditService, rbacService)
+	enterpriseHandler.SetOrgKeyring(orgKeyring)
+	enterpriseHandler.SetAuthService(authService)
+	networkPolicyService := enterprise.NewNetworkPolicyService(database.GetDB(), auditService)
+	enterpriseHandler.SetNetworkPolicyService(networkPolicyService)
+	enterpriseHandler.SetIdentityService(enterprise.NewIdentityService(database.GetDB(), auditService))
+	// Organization AI region policies restrict which providers serve members' requests
+	aiRegionPolicyService := enterprise.NewAIRegionPolicyService(database.GetDB(), auditService)
+	enterpriseHandler.SetAIRegionPolicyService(aiRegionPolicyService)
+	aiRouter.SetRegionPolicy(aiRegionPolicyService)
 
 	// Run enterprise migrations
 	if err := database.GetDB().AutoMigrate(
@@ -942,6 +1215,12 @@ func main() {
 		&enterprise.AuditLog{},
 		&enterprise.RateLimit{},
 		&enterprise.Invitation{},
+		&enterprise.NetworkPolicy{},
+		&enterprise.AIRegionPolicy{},
+		&enterprise.UserIdentity{},
+		&enterprise.AccountMerge{},
+		&enterprise.OrganizationDomain{},
+		&enterprise.PendingIdentityLink{},
 	); err != nil {
 		startupRegistry.MarkDegraded("enterprise_features", startup.TierOptional, "Enterprise migrations completed with warnings", map[string]any{
 			"error": err.Error(),
@@ -951,6 +1230,21 @@ func main() {
 	}
 	log.Println("Enterprise Features initialized (SSO/SAML, SCIM, RBAC, Audit Logs)")
 
+	// Management API: idempotent organization resources for Terraform and
+	// other IaC tools, authenticated with personal management tokens.
+	// Organization audit events are fanned out to configured webhooks.
+	managementService := management.NewService(database.GetDB(), rbacService, auditService)
+	if err := managementService.AutoMigrate(); err != nil {
+		log.Printf("WARNING: Management API migration completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("management_api", startup.TierOptional, "Management API migration completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("management_api", startup.TierOptional, "Management API ready", nil)
+	}
+	auditService.AddListener(managementService.DeliverAuditEvent)
+	managementHandler := handlers.NewManagementHandler(managementService)
+
 	// Initialize Autonomous Agent System (CRITICAL Replit parity feature)
 	// This enables AI-powered autonomous building, testing, and deployment
 	autonomousAIAdapter := autonomous.NewAIAdapter(aiRouter, byokManager)
@@ -973,13 +1267,100 @@ func main() {
 	startupRegistry.MarkReady("collaboration", startup.TierOptional, "Real-time collaboration hub started", nil)
 	collaborationHandler := handlers.NewCollaborationHandler(collabHub, collabAccessor.ResolveProjectAccess)
 
+	// Project activity feed: edits, builds, deployments, comments and joins,
+	// pushed live to the project's collaboration room
+	activityService := activity.NewService(database.GetDB())
+	if err := activity.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Activity feed migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("activity_feed", startup.TierOptional, "Activity feed migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("activity_feed", startup.TierOptional, "Project activity feed ready", nil)
+	}
+	activityService.SetPublisher(func(event activity.Event) {
+		collabHub.BroadcastProjectActivity(event.ProjectID, event.ActorID, event.ActorName, event.OccurredAt, event)
+	})
+	collabHub.SetJoinHook(func(projectID, userID uint, username string) {
+		if err := activityService.Record(context.Background(), activity.Event{
+			ProjectID: projectID,
+			ActorID:   userID,
+			ActorName: username,
+			Kind:      activity.KindCollaboratorJoined,
+			Action:    "joined",
+			Summary:   "joined the project",
+		}); err != nil {
+			log.Printf("activity: failed to record join of user %d to project %d: %v", userID, projectID, err)
+		}
+	})
+	baseHandler.Activity = activityService
+	commentsHandler.Activity = activityService
+	commentsHandler.Access = collabAccessor.ResolveProjectAccess
+	versionHandler.Access = collabAccessor.ResolveProjectAccess
+	pipelineHandler.SetAccessResolver(collabAccessor.ResolveProjectAccess)
+	dockerizeHandler.SetAccessResolver(collabAccessor.ResolveProjectAccess)
+	environmentLockHandler.SetAccessResolver(collabAccessor.ResolveProjectAccess)
+	envCheckHandler.SetAccessResolver(collabAccessor.ResolveProjectAccess)
+	projectTokenHandler.SetAccessResolver(collabAccessor.ResolveProjectAccess)
+	if notebookHandler != nil {
+		notebookHandler.SetAccessResolver(collabAccessor.ResolveProjectAccess)
+	}
+	activityRecorder := &activityBridge{service: activityService}
+	deployService.SetStatusObserver(activityRecorder)
+	hostingService.SetStatusObserver(activityRecorder)
+	agentManager.SetBuildActivitySink(activityRecorder)
+	activityHandler := handlers.NewActivityHandler(activityService, collabAccessor.ResolveProjectAccess)
+
+	// Project restore points: whole-tree snapshots taken before builds are
+	// applied, git pulls and bulk replacements, and on request
+	restorePointService := restorepoints.NewService(database.GetDB())
+	if err := restorepoints.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Restore point migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("restore_points", startup.TierOptional, "Restore point migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("restore_points", startup.TierOptional, "Project restore points ready", nil)
+	}
+	restorePointService.SetVersionRecorder(handlers.CreateFileVersion)
+	agentManager.SetRestorePoints(restorePointService)
+	gitHandler.SetRestorePoints(restorePointService)
+	searchHandler.SetRestorePoints(restorePointService)
+	restorePointHandler := handlers.NewRestorePointHandler(restorePointService, collabAccessor.ResolveProjectAccess)
+	symbolHandler := handlers.NewSymbolHandler(symbolService, collabAccessor.ResolveProjectAccess)
+
+	// Retention: prune file versions, restore points and completed build
+	// payloads past each plan's limits; admins can dry-run and override
+	var retentionCancel context.CancelFunc
+	retentionService := retention.NewService(database.GetDB())
+	retentionHandler := handlers.NewRetentionHandler(retentionService)
+	if err := retention.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Retention migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("retention_pruning", startup.TierOptional, "Retention migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		retentionCtx, cancel := context.WithCancel(context.Background())
+		retentionCancel = cancel
+		retentionService.Start(retentionCtx, getEnvDuration("RETENTION_PRUNE_INTERVAL", retention.DefaultPruneInterval))
+		startupRegistry.MarkReady("retention_pruning", startup.TierOptional, "Retention pruning job started", nil)
+	}
+
 	// Initialize Key Rotation Handler (admin-only)
 	rotationHandler := handlers.NewRotationHandler(database.GetDB())
+	rotationRunner := secrets.NewRotationRunner(database.GetDB(), secretsManager)
+	rotationRunner.ResumeInterrupted()
+	rotationHandler.SetRotationRunner(rotationRunner)
 	log.Println("Key Rotation Handler initialized (admin-only)")
 	startupRegistry.MarkReady("admin_controls", startup.TierOptional, "Admin controls initialized", nil)
 
 	// Initialize Usage Tracker for quota enforcement (REVENUE PROTECTION)
+	var usageRolloverCancel context.CancelFunc
+	var storageReconcileCancel context.CancelFunc
+	var quotaOverrideExpiryCancel context.CancelFunc
 	usageTracker := usage.NewTracker(database.GetDB(), redisCache)
+	usageTracker.SetAuditLogger(auditService)
+	meteredAI.SetUsageTracker(usageTracker)
 	if err := usageTracker.Migrate(); err != nil {
 		startupRegistry.MarkDegraded("usage_tracking", startup.TierOptional, "Usage tracker migration completed with warnings", map[string]any{
 			"error": err.Error(),
@@ -987,22 +1368,198 @@ func main() {
 		log.Printf("WARNING: Usage tracker migration had issues: %v", err)
 	} else {
 		startupRegistry.MarkReady("usage_tracking", startup.TierOptional, "Usage tracking initialized", nil)
+
+		// Snapshot per-user/org usage when a billing period closes
+		usageRolloverCtx, cancel := context.WithCancel(context.Background())
+		usageRolloverCancel = cancel
+		usageTracker.StartRollover(usageRolloverCtx, getEnvDuration("USAGE_ROLLOVER_INTERVAL", usage.DefaultRolloverInterval))
+
+		// Recompute per-project storage from the files table and fix drifted sizes
+		storageReconcileCtx, cancel := context.WithCancel(context.Background())
+		storageReconcileCancel = cancel
+		usageTracker.StartStorageReconciler(storageReconcileCtx, getEnvDuration("STORAGE_RECONCILE_INTERVAL", usage.DefaultStorageReconcileInterval))
+
+		// Close out lapsed quota overrides so limits revert with an audit trail
+		quotaOverrideExpiryCtx, cancel := context.WithCancel(context.Background())
+		quotaOverrideExpiryCancel = cancel
+		usageTracker.StartOverrideExpiry(quotaOverrideExpiryCtx, getEnvDuration("QUOTA_OVERRIDE_EXPIRY_INTERVAL", usage.DefaultOverrideExpiryInterval))
+	}
+	paymentHandler.SetPlanChangeRecorder(usageTracker)
+
+	// Referral program: tracked invite links, credited when referrals pay
+	referralService := referrals.NewService(database.GetDB())
+	referralService.SetRewards(
+		getEnvFloat("REFERRAL_REWARD_USD", referrals.DefaultReferrerRewardUSD),
+		getEnvFloat("REFERRAL_REFERRED_REWARD_USD", referrals.DefaultReferredRewardUSD),
+		getEnvInt("REFERRAL_MONTHLY_REWARD_CAP", referrals.DefaultMonthlyRewardCap),
+	)
+	if err := referrals.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Referral migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("referrals", startup.TierOptional, "Referral migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		paymentHandler.SetReferralConverter(referralService)
+		startupRegistry.MarkReady("referrals", startup.TierOptional, "Referral program ready", nil)
 	}
+	referralHandler := handlers.NewReferralHandler(referralService)
 	usageHandler := handlers.NewUsageHandlers(database.GetDB(), usageTracker)
+	usageHandler.SetOrgPermissions(rbacService)
 	quotaChecker := middleware.NewQuotaChecker(usageTracker)
 	completionService.SetUsageTracker(usageTracker)
 	if executionHandler != nil {
 		executionHandler.SetUsageTracker(usageTracker)
+		executionHandler.SetExecutionQuota(quotaChecker) // Reserve timeout-sized minutes, settle with measured time
+		executionHandler.SetStorageQuota(quotaChecker)   // Artifacts saved into projects
+	}
+	if notebookHandler != nil {
+		notebookHandler.SetExecutionQuota(quotaChecker) // Each cell reserves the cell timeout and settles its runtime
+	}
+	projectTokenHandler.SetStorageUsage(usageTracker) // Output writes count against the project payer's storage
+
+	// Abuse detection: screen executions for mining and spam, throttle or
+	// suspend free-tier accounts pending admin review
+	abuseConfig := abuse.DefaultConfig()
+	abuseConfig.ThrottleScore = getEnvInt("ABUSE_THROTTLE_SCORE", abuseConfig.ThrottleScore)
+	abuseConfig.SuspendScore = getEnvInt("ABUSE_SUSPEND_SCORE", abuseConfig.SuspendScore)
+	abuseConfig.ThrottledRunsPerHour = getEnvInt("ABUSE_THROTTLED_RUNS_PER_HOUR", abuseConfig.ThrottledRunsPerHour)
+	abuseConfig.CPUMinutesPerHour = getEnvFloat("ABUSE_FREE_CPU_MINUTES_PER_HOUR", abuseConfig.CPUMinutesPerHour)
+	if domains := os.Getenv("ABUSE_DISPOSABLE_DOMAINS"); domains != "" {
+		abuseConfig.DisposableDomains = strings.Split(domains, ",")
+	}
+	abuseService := abuse.NewService(database.GetDB(), abuseConfig)
+	if err := abuseService.AutoMigrate(); err != nil {
+		log.Printf("WARNING: Abuse detection migration completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("abuse_detection", startup.TierOptional, "Abuse detection migration completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("abuse_detection", startup.TierOptional, "Abuse detection ready", nil)
 	}
+	if executionHandler != nil {
+		executionHandler.SetAbuseService(abuseService)
+	}
+	abuseHandler := handlers.NewAbuseHandler(abuseService)
+	abuseGate := middleware.AbuseGate(abuseService)
 	log.Println("Usage Tracking & Quota Enforcement initialized (projects, storage, AI, execution)")
 	log.Printf("   - Active plans: %s", formatConfiguredPlansForLog(payments.GetAllPlans()))
 
+	// Trash: soft-deleted projects/files stay restorable for 30 days, then purge.
+	var trashPurgeCancel context.CancelFunc
+	trashService := trash.NewService(database.GetDB())
+	trashService.SetUsageRefresher(usageTracker)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	trashHandler.SetProjectCacheInvalidator(optimizedHandler)
+	trashPurgeCtx, cancelTrashPurge := context.WithCancel(context.Background())
+	trashPurgeCancel = cancelTrashPurge
+	trashService.Start(trashPurgeCtx, getEnvDuration("TRASH_PURGE_INTERVAL", trash.DefaultPurgeInterval))
+	startupRegistry.MarkReady("trash_purge", startup.TierOptional, "Trash purge job started", nil)
+
+	// Scheduled tasks: cron runs of a project command, charged as execution minutes
+	var schedulerCancel context.CancelFunc
+	scheduleService := schedules.NewService(database.GetDB())
+	scheduleService.SetQuota(usageTracker)
+	scheduleService.SetNotifier(notificationService)
+	if executionHandler != nil {
+		scheduleService.SetRunner(executionHandler)
+	}
+	scheduleHandler := handlers.NewScheduleHandler(scheduleService)
+	if err := schedules.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Scheduled task migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("scheduled_tasks", startup.TierOptional, "Scheduled task migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		schedulerCtx, cancel := context.WithCancel(context.Background())
+		schedulerCancel = cancel
+		scheduleService.Start(schedulerCtx, getEnvDuration("SCHEDULED_TASK_INTERVAL", schedules.DefaultTickInterval))
+		startupRegistry.MarkReady("scheduled_tasks", startup.TierOptional, "Scheduled task runner started", nil)
+	}
+
+	// Dependency update agent: verified manifest bumps as auto-merges, PRs or change sets
+	var depUpdatesCancel context.CancelFunc
+	depUpdateService := depupdates.NewService(database.GetDB(), depupdates.NewRegistrySource(packages.NewPackageManagerService(database.GetDB())))
+	depUpdateService.SetQuota(usageTracker)
+	depUpdateService.SetPublisher(gitHandler)
+	depUpdateService.SetNotifier(notificationService)
+	depUpdateService.SetRestorePoints(restorePointService)
+	if executionHandler != nil {
+		depUpdateService.SetRunner(executionHandler)
+	}
+	depUpdateHandler := handlers.NewDependencyUpdateHandler(depUpdateService)
+	if err := depupdates.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Dependency update migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("dependency_updates", startup.TierOptional, "Dependency update migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		depUpdatesCtx, cancel := context.WithCancel(context.Background())
+		depUpdatesCancel = cancel
+		depUpdateService.Start(depUpdatesCtx, getEnvDuration("DEPENDENCY_UPDATE_INTERVAL", depupdates.DefaultTickInterval))
+		startupRegistry.MarkReady("dependency_updates", startup.TierOptional, "Dependency update agent started", nil)
+	}
+
+	// Build outcomes: classified failure causes and flaky build reports
+	buildFailureService := buildfailures.NewService(database.GetDB())
+	buildFailureHandler := handlers.NewBuildFailureHandler(buildFailureService)
+	buildFailureHandler.SetOrgPermissions(rbacService)
+	if err := buildfailures.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Build outcome migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("build_outcomes", startup.TierOptional, "Build outcome migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		agentManager.SetBuildOutcomeRecorder(buildFailureService)
+		startupRegistry.MarkReady("build_outcomes", startup.TierOptional, "Build failure classification ready", nil)
+	}
+
+	// Support bundles: redacted diagnostics archives shared by support token
+	supportBundleService := supportbundles.NewService(database.GetDB())
+	supportBundleService.SetBuildSource(agentManager)
+	supportBundleHandler := handlers.NewSupportBundleHandler(supportBundleService)
+	if err := supportbundles.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Support bundle migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("support_bundles", startup.TierOptional, "Support bundle migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("support_bundles", startup.TierOptional, "Support diagnostics bundles ready", nil)
+	}
+
+	// Build hooks: apex.yaml pre_build, post_build and pre_deploy commands in the sandbox
+	buildHookRunner := buildhooks.NewRunner(database.GetDB())
+	if executionHandler != nil {
+		buildHookRunner.SetExecutor(executionHandler)
+		buildHookRunner.SetQuota(usageTracker)
+		startupRegistry.MarkReady("build_hooks", startup.TierOptional, "apex.yaml build hooks ready", nil)
+	} else {
+		startupRegistry.MarkDegraded("build_hooks", startup.TierOptional, "apex.yaml build hooks fail without code execution", nil)
+	}
+	agentManager.SetBuildHooks(buildHookRunner)
+	deployService.SetPreDeployHooks(buildHookRunner)
+	hostingService.SetPreDeployHooks(buildHookRunner)
+
+	// Onboarding: sample project on first sign-in and a per-org checklist
+	onboardingService := onboarding.NewService(database.GetDB())
+	onboardingHandler := handlers.NewOnboardingHandler(onboardingService, rbacService)
+	if err := onboarding.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Onboarding migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("onboarding", startup.TierOptional, "Onboarding migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		startupRegistry.MarkReady("onboarding", startup.TierOptional, "Onboarding checklist ready", nil)
+	}
+
 	// Initialize Prometheus Metrics and Business Metrics Collector
 	metricsEnabled := metricsEnabledForEnvironment(getEnv("ENVIRONMENT", ""), getEnv("ENABLE_METRICS", "true"), getEnv("METRICS_AUTH_TOKEN", ""))
 	if metricsEnabled {
 		// Set build info
 		m := metrics.Get()
 		m.SetBuildInfo(getEnv("VERSION", "dev"), getEnv("GIT_COMMIT", "unknown"), getEnv("BUILD_DATE", "unknown"))
+		optimizedHandler.SetCacheMetrics(m)
+		wsHubRT.SetQueueMetrics(m)
+		wsHub.SetQueueMetrics(m)
 
 		// Start business metrics collector (collects user/project/subscription counts)
 		businessCollector := metrics.NewBusinessMetricsCollector(database.GetDB(), 30*time.Second)
@@ -1012,6 +1569,8 @@ func main() {
 		log.Println("   - AI metrics: requests_total, tokens_total, cost_dollars, latency")
 		log.Println("   - Execution metrics: total, duration, queue_length, container_usage")
 		log.Println("   - Business metrics: active_users, total_projects, subscriptions")
+		log.Println("   - Cache metrics: hits_total, misses_total (project_list, project, file_tree)")
+		log.Println("   - WebSocket metrics: dropped_messages_total, queued_messages, slow_clients_disconnected_total")
 		log.Println("   - Metrics endpoint: GET /metrics")
 		startupRegistry.MarkReady("metrics", startup.TierOptional, "Prometheus metrics initialized", nil)
 	} else {
@@ -1022,7 +1581,23 @@ func main() {
 	server := api.NewServer(database, authService, aiRouter, byokManager)
 	server.SetReadinessRegistry(startupRegistry)
 	server.SetUsageTracker(usageTracker)
+	server.SetOnboardingService(onboardingService)
+	server.SetReferralService(referralService)
+	server.SetStorageQuota(quotaChecker)
+	server.SetSymbolIndexer(symbolService)
 	server.SetCacheStatusProvider(redisCache.Status)
+
+	// Brute-force protection on password logins (progressive lockout, IP
+	// reputation, optional Turnstile/hCaptcha challenge)
+	loginGuard := auth.NewLoginGuard(auth.DefaultLoginGuardConfig())
+	if captcha, err := auth.CaptchaVerifierFromEnv(); err != nil {
+		log.Printf("WARNING: Login CAPTCHA disabled: %v", err)
+	} else if captcha != nil {
+		loginGuard.SetCaptchaVerifier(captcha)
+		log.Printf("Login CAPTCHA enabled (%s)", captcha.Provider())
+	}
+	loginGuard.OnSecurityEvent(api.LoginSecurityEventHandler(auditService, notificationService))
+	server.SetLoginGuard(loginGuard)
 	mobileFlags := mobile.LoadFeatureFlagsFromEnv()
 	var mobileBuildProvider mobile.MobileBuildProvider
 	var mobileSubmitProvider mobile.MobileSubmissionProvider
@@ -1078,6 +1653,82 @@ func main() {
 		log.Fatalf("storage init failed: %v", err)
 	}
 	server.SetStorageProvider(storageProvider)
+	preview.SetBlobStore(storageProvider)
+	runArtifacts.SetBlobStore(storageProvider)
+	gitService.SetBlobStore(storageProvider)
+
+	// Project archival: inactive projects move to the artifact store's cold tier
+	archivalHandler := handlers.NewArchivalHandler(archival.NewService(database.GetDB(), storageProvider))
+	archivalHandler.SetProjectCacheInvalidator(optimizedHandler)
+
+	// GDPR/CCPA: data export, verified account deletion, and org retention purges
+	var privacyRetentionCancel context.CancelFunc
+	privacyService := privacy.NewService(database.GetDB())
+	privacyService.SetMailer(emailSvc)
+	privacyService.SetStorageProvider(storageProvider)
+	privacyService.SetCustomerDeleter(payments.NewStripeService(stripeSecretKey))
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	if err := database.GetDB().AutoMigrate(&privacy.DeletionRequest{}, &privacy.ProjectExport{}); err != nil {
+		log.Printf("WARNING: Privacy migrations completed with warnings: %v", err)
+		startupRegistry.MarkDegraded("data_retention", startup.TierOptional, "Privacy migrations completed with warnings", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		privacyRetentionCtx, cancel := context.WithCancel(context.Background())
+		privacyRetentionCancel = cancel
+		privacyService.Start(privacyRetentionCtx, getEnvDuration("DATA_RETENTION_INTERVAL", privacy.DefaultRetentionInterval))
+		startupRegistry.MarkReady("data_retention", startup.TierOptional, "Data retention job started", nil)
+	}
+
+	// Organization-owned projects and confirmed ownership transfers
+	ownershipService := ownership.NewService(database.GetDB(), rbacService)
+	ownershipService.SetUsageRefresher(usageTracker)
+	ownershipService.SetProjectTokens(projectTokens)
+	ownershipService.SetUserProjectQuota(&projectQuotaBridge{tracker: usageTracker, db: database.GetDB()})
+	optimizedHandler.SetOrgProjectScope(ownershipService)
+	collabAccessor.SetOrgPermissions(rbacService)
+	searchHandler.SetOrgPermissions(rbacService)
+	projectOwnershipHandler := handlers.NewProjectOwnershipHandler(ownershipService)
+	projectOwnershipHandler.SetProjectCacheInvalidator(optimizedHandler)
+	if err := database.GetDB().AutoMigrate(&ownership.ProjectTransfer{}); err != nil {
+		log.Printf("WARNING: Project transfer migrations completed with warnings: %v", err)
+	}
+
+	// Agent marketplace: published custom agent roles, applied to the
+	// installing user's builds
+	agentMarketService := agentmarket.NewService(database.GetDB())
+	if err := agentmarket.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Agent marketplace migrations completed with warnings: %v", err)
+	}
+	agentManager.SetAgentProfileSource(agentMarketService)
+	agentMarketHandler := agentmarket.NewHandler(agentMarketService)
+
+	// Project apex.md and organization AI instructions, applied to builds,
+	// completions and AI chat on the project
+	instructionsService := instructions.NewService(database.GetDB(), ownershipService)
+	agentManager.SetProjectInstructionsSource(instructionsService)
+	completionService.SetProjectInstructions(instructionsService)
+	// Completions harvest definitions and sibling files from projects the
+	// user can read, and debounce typing-driven requests
+	completionService.SetContextSources(ownershipService, symbolService)
+	completionService.SetDebounce(getEnvDuration("COMPLETION_DEBOUNCE", completions.DefaultDebounce))
+	// Platform completions are routed to the model with the best acceptance
+	// rate per language among these "provider:model" candidates
+	completionService.SetModelCandidates(completions.ParseModelCandidates(os.Getenv("COMPLETION_MODEL_CANDIDATES")))
+	// Short completions try these small models first, in order, and escalate
+	// to the standard model when they are slow or fail
+	completionService.SetFastModels(completions.ParseModelCandidates(os.Getenv("COMPLETION_FAST_MODELS")))
+	server.SetProjectInstructions(instructionsService)
+	projectInstructionsHandler := handlers.NewProjectInstructionsHandler(instructionsService)
+
+	// Organization coding standards: injected into members' build prompts and
+	// checked against generated files during review
+	codingStandardsService := codestandards.NewService(database.GetDB())
+	if err := codestandards.AutoMigrate(database.GetDB()); err != nil {
+		log.Printf("WARNING: Coding standards migration completed with warnings: %v", err)
+	}
+	agentManager.SetCodingStandardsSource(codingStandardsService)
+	enterpriseHandler.SetCodingStandardsService(codingStandardsService)
 
 	// Setup routes
 	router := setupRoutes(
@@ -1091,15 +1742,44 @@ func main() {
 		communityHandler, hostingHandler, databaseHandler, debuggingHandler,
 		completionsHandler, extensionsHandler, enterpriseHandler, collabHub, collaborationHandler,
 		optimizedHandler,
-		byokHandler,           // BYOK API key management and model selection
-		exportHandler,         // GitHub export (push projects to GitHub)
-		usageHandler,          // Usage tracking and quota API endpoints
-		quotaChecker,          // Quota enforcement middleware
-		rotationHandler,       // Key rotation (admin)
-		spendHandler,          // Spend tracking dashboard
-		budgetHandler,         // Budget caps enforcement
-		budgetMiddleware,      // Budget enforcement middleware
-		protectedPathsHandler, // Protected paths management
+		byokHandler,                    // BYOK API key management and model selection
+		exportHandler,                  // GitHub export (push projects to GitHub)
+		usageHandler,                   // Usage tracking and quota API endpoints
+		quotaChecker,                   // Quota enforcement middleware
+		rotationHandler,                // Key rotation (admin)
+		spendHandler,                   // Spend tracking dashboard
+		budgetHandler,                  // Budget caps enforcement
+		budgetMiddleware,               // Budget enforcement middleware
+		protectedPathsHandler,          // Protected paths management
+		analyticsHandler,               // Per-user AI analytics
+		notificationHandler,            // In-app notification center
+		trashHandler,                   // Trash/restore for deleted projects and files
+		archivalHandler,                // Project archive/unarchive (cold storage)
+		privacyHandler,                 // GDPR data export and account deletion
+		projectOwnershipHandler,        // Org-owned projects and ownership transfers
+		agentMarketHandler,             // Custom agent role marketplace
+		projectInstructionsHandler,     // Project apex.md AI instructions
+		notebookHandler,                // Notebook kernels and .ipynb files
+		scheduleHandler,                // Cron-scheduled project tasks
+		depUpdateHandler,               // Scheduled dependency update agent
+		buildFailureHandler,            // Build failure causes and flaky build reports
+		supportBundleHandler,           // Redacted support diagnostics bundles
+		onboardingHandler,              // Onboarding checklist and sample project
+		referralHandler,                // Referral links and dashboard
+		warehouseHandler,               // Admin analytics trends and warehouse exports
+		abuseHandler,                   // Execution abuse status, appeals and review queue
+		abuseGate,                      // Refuses executions for suspended or throttled accounts
+		managementHandler,              // Management tokens and the Terraform management API
+		managementService.Middleware(), // Authenticates management API tokens
+		pipelineHandler,                // Project deployment environments and promotions
+		dockerizeHandler,               // Verified Dockerfile generation
+		environmentLockHandler,         // Project apex.lock verification
+		envCheckHandler,                // Missing environment variable checks
+		projectTokenHandler,            // Project service tokens and the project API
+		activityHandler,                // Project activity feed
+		restorePointHandler,            // Project restore points
+		retentionHandler,               // Admin retention policies, overrides and pruning runs
+		symbolHandler,                  // Project symbol search and file outlines
 	)
 
 	// Activate the full router now that all services are initialized.
@@ -1151,6 +1831,11 @@ func main() {
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
+	if previewTLSServer != nil {
+		if err := previewTLSServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Preview TLS server shutdown error: %v", err)
+		}
+	}
 	log.Println("HTTP server stopped")
 
 	// 2. Stop mobile build poller before shutting down dependent services.
@@ -1159,12 +1844,91 @@ func main() {
 		log.Println("Mobile EAS build poller stopped")
 	}
 
+	if aiAnalyticsCancel != nil {
+		aiAnalyticsCancel()
+		log.Println("AI analytics rollup job stopped")
+	}
+
+	if warehouseCancel != nil {
+		warehouseCancel()
+		log.Println("Analytics warehouse job stopped")
+	}
+
+	if spendAnomalyCancel != nil {
+		spendAnomalyCancel()
+		log.Println("Spend anomaly detector stopped")
+	}
+
+	if usageRolloverCancel != nil {
+		usageRolloverCancel()
+		log.Println("Usage rollover job stopped")
+	}
+
+	if storageReconcileCancel != nil {
+		storageReconcileCancel()
+		log.Println("Storage reconciliation job stopped")
+	}
+
+	if quotaOverrideExpiryCancel != nil {
+		quotaOverrideExpiryCancel()
+		log.Println("Quota override expiry job stopped")
+	}
+
+	if trashPurgeCancel != nil {
+		trashPurgeCancel()
+		log.Println("Trash purge job stopped")
+	}
+
+	if searchIndexCancel != nil {
+		searchIndexCancel()
+		log.Println("Search index refresh stopped")
+	}
+
+	if symbolIndexCancel != nil {
+		symbolIndexCancel()
+		log.Println("Symbol index refresh stopped")
+	}
+
+	if retentionCancel != nil {
+		retentionCancel()
+		log.Println("Retention pruning job stopped")
+	}
+
+	if privacyRetentionCancel != nil {
+		privacyRetentionCancel()
+		log.Println("Data retention job stopped")
+	}
+
+	if schedulerCancel != nil {
+		schedulerCancel()
+		log.Println("Scheduled task runner stopped")
+	}
+
+	if projectTokenCancel != nil {
+		projectTokenCancel()
+		log.Println("Project token rotation stopped")
+	}
+
+	if depUpdatesCancel != nil {
+		depUpdatesCancel()
+		log.Println("Dependency update agent stopped")
+	}
+
 	// 3. Stop all preview backend processes (prevents orphan child processes)
 	if sr := previewHandler.GetServerRunner(); sr != nil {
 		sr.StopAll(shutdownCtx)
 		log.Println("Preview backend processes stopped")
 	}
 
+	// Deliver log lines still queued for drains
+	logDrains.Close()
+	log.Println("Log drains flushed")
+
+	if notebookKernels != nil {
+		notebookKernels.Close()
+		log.Println("Notebook kernels stopped")
+	}
+
 	// 4. Shutdown agent manager (cancels in-flight builds, closes task queues)
 	agentManager.Shutdown()
 	log.Println("Agent manager stopped")
@@ -1215,6 +1979,11 @@ func newStartupRegistry() *startup.Registry {
 	registry.Register("enterprise_features", startup.TierOptional, "Waiting for enterprise features", nil)
 	registry.Register("autonomous_agent", startup.TierOptional, "Waiting for autonomous agent system", nil)
 	registry.Register("collaboration", startup.TierOptional, "Waiting for collaboration hub", nil)
+	registry.Register("activity_feed", startup.TierOptional, "Waiting for project activity feed", nil)
+	registry.Register("restore_points", startup.TierOptional, "Waiting for project restore points", nil)
+	registry.Register("retention_pruning", startup.TierOptional, "Waiting for retention pruning job", nil)
+	registry.Register("search_index", startup.TierOptional, "Waiting for search index", nil)
+	registry.Register("symbol_index", startup.TierOptional, "Waiting for symbol index", nil)
 	registry.Register("admin_controls", startup.TierOptional, "Waiting for admin controls initialization", nil)
 	registry.Register("usage_tracking", startup.TierOptional, "Waiting for usage tracker", nil)
 	registry.Register("metrics", startup.TierOptional, "Waiting for metrics subsystem", nil)
@@ -1308,6 +2077,68 @@ func resolvePreviewRuntimeVerify(chromePath string) (bool, string) {
 	return false, "non_production_default"
 }
 
+// startPreviewTLSServer serves the API over HTTPS on PREVIEW_TLS_ADDR so
+// previews that need secure cookies or build https:// URLs behave as in
+// production. PREVIEW_TLS_CERT_FILE/PREVIEW_TLS_KEY_FILE supply a real
+// certificate; otherwise a wildcard dev certificate for PREVIEW_TLS_HOSTS
+// is issued from a local CA whose certificate is served at
+// /api/v1/preview/dev-cert.
+func startPreviewTLSServer(handler http.Handler, previewHandler *handlers.PreviewHandler) *http.Server {
+	addr := strings.TrimSpace(os.Getenv("PREVIEW_TLS_ADDR"))
+	if addr == "" {
+		return nil
+	}
+
+	var certificate tls.Certificate
+	certFile, keyFile := os.Getenv("PREVIEW_TLS_CERT_FILE"), os.Getenv("PREVIEW_TLS_KEY_FILE")
+	if certFile != "" && keyFile != "" {
+		loaded, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			log.Printf("WARNING: Preview TLS disabled: %v", err)
+			return nil
+		}
+		certificate = loaded
+	} else {
+		dir := os.Getenv("PREVIEW_TLS_DIR")
+		if dir == "" {
+			cacheDir, err := os.UserCacheDir()
+			if err != nil {
+				cacheDir = os.TempDir()
+			}
+			dir = filepath.Join(cacheDir, "apex-build", "devcert")
+		}
+		authority, err := devcert.LoadOrCreate(dir)
+		if err != nil {
+			log.Printf("WARNING: Preview TLS disabled: dev CA: %v", err)
+			return nil
+		}
+		issued, err := authority.Issue(splitCSV(getEnv("PREVIEW_TLS_HOSTS", "localhost,*.localhost,127.0.0.1")))
+		if err != nil {
+			log.Printf("WARNING: Preview TLS disabled: dev certificate: %v", err)
+			return nil
+		}
+		certificate = issued
+		previewHandler.SetDevCertificate(authority.CertPEM())
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12},
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      120 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Printf("WARNING: Preview TLS server stopped: %v", err)
+		}
+	}()
+	log.Printf("Preview TLS listener started on %s", addr)
+	return server
+}
+
 func configureTrustedClientIP(router *gin.Engine) {
 	if router == nil {
 		return
@@ -1468,6 +2299,35 @@ func setupRoutes(
 	budgetHandler *handlers.BudgetHandler, // Budget caps enforcement
 	budgetMiddleware gin.HandlerFunc, // Budget enforcement middleware
 	protectedPathsHandler *handlers.ProtectedPathsHandler, // Protected paths management
+	analyticsHandler *handlers.AnalyticsHandler, // Per-user AI analytics
+	notificationHandler *handlers.NotificationHandler, // In-app notification center
+	trashHandler *handlers.TrashHandler, // Trash/restore for deleted projects and files
+	archivalHandler *handlers.ArchivalHandler, // Project archive/unarchive (cold storage)
+	privacyHandler *handlers.PrivacyHandler, // GDPR data export and account deletion
+	projectOwnershipHandler *handlers.ProjectOwnershipHandler, // Org-owned projects and ownership transfers
+	agentMarketHandler *agentmarket.Handler, // Custom agent role marketplace
+	projectInstructionsHandler *handlers.ProjectInstructionsHandler, // Project apex.md AI instructions
+	notebookHandler *handlers.NotebookHandler, // Notebook kernels and .ipynb files
+	scheduleHandler *handlers.ScheduleHandler, // Cron-scheduled project tasks
+	depUpdateHandler *handlers.DependencyUpdateHandler, // Scheduled dependency update agent
+	buildFailureHandler *handlers.BuildFailureHandler, // Build failure causes and flaky build reports
+	supportBundleHandler *handlers.SupportBundleHandler, // Redacted support diagnostics bundles
+	onboardingHandler *handlers.OnboardingHandler, // Onboarding checklist and sample project
+	referralHandler *handlers.ReferralHandler, // Referral links and dashboard
+	warehouseHandler *handlers.WarehouseHandler, // Admin analytics trends and warehouse exports
+	abuseHandler *handlers.AbuseHandler, // Execution abuse status, appeals and review queue
+	abuseGate gin.HandlerFunc, // Refuses executions for suspended or throttled accounts
+	managementHandler *handlers.ManagementHandler, // Management tokens and the Terraform management API
+	managementAuth gin.HandlerFunc, // Authenticates management API tokens
+	pipelineHandler *handlers.PipelineHandler, // Project deployment environments and promotions
+	dockerizeHandler *handlers.DockerizeHandler, // Verified Dockerfile generation
+	environmentLockHandler *handlers.EnvironmentLockHandler, // Project apex.lock verification
+	envCheckHandler *handlers.EnvCheckHandler, // Missing environment variable checks
+	projectTokenHandler *handlers.ProjectTokenHandler, // Project service tokens and the project API
+	activityHandler *handlers.ActivityHandler, // Project activity feed
+	restorePointHandler *handlers.RestorePointHandler, // Project restore points
+	retentionHandler *handlers.RetentionHandler, // Admin retention policies, overrides and pruning runs
+	symbolHandler *handlers.SymbolHandler, // Project symbol search and file outlines
 ) *gin.Engine {
 	// Set gin mode based on environment
 	if os.Getenv("ENVIRONMENT") == "production" {
@@ -1583,6 +2443,9 @@ func setupRoutes(
 		// Community/Sharing Marketplace public endpoints (no auth required for viewing)
 		communityHandler.RegisterRoutes(v1)
 
+		// Referral links (GET /r/:code counts the click and redirects to signup)
+		referralHandler.RegisterPublicRoutes(v1)
+
 		// Preview proxy endpoints (token-auth via query param for iframe embedding)
 		previewProxy := v1.Group("/preview")
 		{
@@ -1591,16 +2454,34 @@ func setupRoutes(
 			// Backend proxy: routes fetch() calls from the preview frontend to the running backend
 			previewProxy.Any("/backend-proxy/:projectId", previewHandler.ProxyBackend)
 			previewProxy.Any("/backend-proxy/:projectId/*path", previewHandler.ProxyBackend)
+			// Port proxy: extra backend ports exposed by name (e.g. a frontend dev server next to an API)
+			previewProxy.Any("/port-proxy/:projectId/:port", previewHandler.ProxyPort)
+			previewProxy.Any("/port-proxy/:projectId/:port/*path", previewHandler.ProxyPort)
+			// Dev CA for the preview TLS listener, for users to trust locally
+			previewProxy.GET("/dev-cert", previewHandler.GetDevCertificate)
 		}
 
+		// Error reports from hosted apps (Sentry-compatible; DSN key auth)
+		v1.POST("/errors/api/:projectId/store/", hostingHandler.IngestErrorReport)
+		v1.POST("/errors/api/:projectId/envelope/", hostingHandler.IngestErrorReport)
+		// CSP violation reports from visitors' browsers (report-uri / report-to)
+		v1.POST("/csp-reports/:deploymentId", hostingHandler.IngestCSPReport)
+
 		// Stripe webhook — must be unauthenticated (Stripe sends this, not users)
 		// Raw body is required for signature verification — do NOT add body parsers here
 		v1.POST("/billing/webhook", paymentHandler.HandleWebhook)
 
+		// GitHub push webhooks for auto-synced repositories; verified with the
+		// per-repository secret rather than a user session.
+		v1.POST("/git/webhooks/github/:projectId", gitHandler.HandleGitHubWebhook)
+
 		// Build canary poll endpoint. Authenticated build/detail/preview routes
 		// remain protected; this route accepts only per-build read-only tokens.
 		buildHandler.RegisterPublicRoutes(v1)
 
+		// Support bundle download by support token (no account access)
+		supportBundleHandler.RegisterPublicRoutes(v1)
+
 		// CSRF token endpoint — public GET, issues a time-limited HMAC token.
 		// The frontend fetches this once and attaches it as X-CSRF-Token on all
 		// state-mutating requests to the protected group.
@@ -1608,14 +2489,86 @@ func setupRoutes(
 			c.JSON(http.StatusOK, gin.H{"token": middleware.GenerateCSRFToken()})
 		})
 
+		// Management API (Terraform provider). Token-authenticated rather than
+		// session-authenticated, so no CSRF check; org network policies still apply.
+		manage := v1.Group("/manage")
+		manage.Use(managementAuth)
+		manage.Use(enterpriseHandler.NetworkPolicyMiddleware())
+		managementHandler.RegisterRoutes(manage)
+
+		// Project API for scheduled runs and hosted apps, authenticated by
+		// the project's service token and limited to that project
+		projectTokenHandler.RegisterAPIRoutes(v1)
+
 		// Protected routes (authentication required)
 		protected := v1.Group("/")
 		protected.Use(server.AuthMiddleware())
 		protected.Use(middleware.CSRFProtection())
+		protected.Use(enterpriseHandler.NetworkPolicyMiddleware()) // Organization IP allowlists and geo restrictions
 		{
 			// Usage tracking and quota API endpoints (REVENUE PROTECTION)
 			usageHandler.RegisterUsageRoutes(protected)
 
+			// Per-user AI analytics (not subject to AI quota)
+			analyticsHandler.RegisterRoutes(protected)
+
+			// In-app notification center (spend anomaly alerts, etc.)
+			notificationHandler.RegisterRoutes(protected)
+
+			// Trash: restoring a project counts against the project quota
+			trashHandler.RegisterRoutes(protected, quotaChecker.CheckProjectQuota())
+
+			// Project archival: unarchiving counts against the project quota
+			archivalHandler.RegisterRoutes(protected, quotaChecker.CheckProjectQuota())
+
+			// Account data export and verified deletion (GDPR/CCPA)
+			privacyHandler.RegisterRoutes(protected)
+
+			// Organization-owned projects and ownership transfers
+			projectOwnershipHandler.RegisterRoutes(protected)
+
+			// Agent marketplace: publish, install, pin and rate custom agent roles
+			agentMarketHandler.RegisterRoutes(protected)
+
+			// Project AI instructions (apex.md)
+			projectInstructionsHandler.RegisterRoutes(protected)
+
+			// Scheduled tasks (cron runs with history and failure notifications)
+			scheduleHandler.RegisterRoutes(protected)
+
+			// Dependency update agent (policy, runs, change sets)
+			depUpdateHandler.RegisterRoutes(protected)
+
+			// Build failure insights (GET /build-insights/failures)
+			buildFailureHandler.RegisterRoutes(protected)
+
+			// Support diagnostics bundles (create, list, download, revoke)
+			supportBundleHandler.RegisterRoutes(protected)
+
+			// Project activity feed (GET /projects/:id/activity)
+			activityHandler.RegisterRoutes(protected)
+
+			// Project restore points (list, diff and restore whole-tree snapshots)
+			restorePointHandler.RegisterRoutes(protected)
+
+			// Project symbols (go-to-symbol search and file outlines)
+			symbolHandler.RegisterRoutes(protected)
+
+			// Guided setup checklist (GET /onboarding)
+			onboardingHandler.RegisterRoutes(protected)
+
+			// Referral dashboard (link, clicks, signups, earned credits)
+			referralHandler.RegisterRoutes(protected)
+
+			// Execution abuse status and appeals
+			abuseHandler.RegisterRoutes(protected)
+
+			// Management API tokens (used by the Terraform provider)
+			managementHandler.RegisterTokenRoutes(protected)
+
+			// Provider health and circuit breaker state (not quota-gated)
+			protected.GET("/ai/providers/health", server.GetAIProviderHealth)
+
 			// AI endpoints - with quota and budget enforcement
 			ai := protected.Group("/ai")
 			ai.Use(quotaChecker.CheckAIQuota()) // Enforce AI request quota
@@ -1654,9 +2607,11 @@ func setupRoutes(
 				projects.GET("/:id/mobile/builds/:buildId/artifacts", server.GetProjectMobileBuildArtifacts)
 
 				// File endpoints under projects - using optimized handler
-				// Storage quota checked on file creation
-				projects.POST("/:id/files", quotaChecker.CheckStorageQuota(1024*1024), server.CreateFile) // Estimate 1MB
-				projects.GET("/:id/files", optimizedHandler.GetProjectFilesOptimized)                     // Optimized: no content loading for list
+				// Storage quota checked in the handlers against the real content size
+				projects.POST("/:id/files", server.CreateFile)
+				projects.POST("/:id/files/upload", server.UploadFile)
+				projects.POST("/:id/uploads", server.CreateUpload)
+				projects.GET("/:id/files", optimizedHandler.GetProjectFilesOptimized) // Optimized: no content loading for list
 
 				// Asset upload endpoints — users upload images, CSVs, PDFs etc for AI agents to use
 				projects.POST("/:id/assets", server.UploadAsset)
@@ -1677,15 +2632,28 @@ func setupRoutes(
 			files := protected.Group("/files")
 			{
 				files.GET("/:id", server.GetFile)
+				files.GET("/:id/raw", server.DownloadFile)
 				files.PUT("/:id", server.UpdateFile)
 				files.DELETE("/:id", server.DeleteFile)
 			}
 
+			// Resumable chunked uploads (tus-style) for large files
+			uploads := protected.Group("/uploads")
+			{
+				uploads.HEAD("/:uploadId", server.GetUpload)
+				uploads.GET("/:uploadId", server.GetUpload)
+				uploads.PATCH("/:uploadId", server.PatchUpload)
+				uploads.DELETE("/:uploadId", server.DeleteUpload)
+			}
+
 			// User profile endpoints
 			user := protected.Group("/user")
 			{
 				user.GET("/profile", server.GetUserProfile)
 				user.PUT("/profile", server.UpdateUserProfile)
+				user.GET("/sessions", server.GetUserSessions)
+				user.DELETE("/sessions/:sessionId", server.RevokeUserSession)
+				user.POST("/sessions/revoke-all", server.RevokeAllUserSessions)
 			}
 
 			// Build/Agent endpoints (the core of APEX.BUILD)
@@ -1755,13 +2723,18 @@ func setupRoutes(
 			// Code Search endpoints
 			searchRoutes := protected.Group("/search")
 			{
-				searchRoutes.POST("", searchHandler.Search)                       // Full search with all options
-				searchRoutes.GET("/quick", searchHandler.QuickSearch)             // Quick search for autocomplete
-				searchRoutes.GET("/symbols", searchHandler.SearchSymbols)         // Symbol search (functions, classes)
-				searchRoutes.GET("/files", searchHandler.SearchFiles)             // File name search
-				searchRoutes.POST("/replace", searchHandler.SearchAndReplace)     // Search & replace
-				searchRoutes.GET("/history", searchHandler.GetSearchHistory)      // Search history
-				searchRoutes.DELETE("/history", searchHandler.ClearSearchHistory) // Clear history
+				searchRoutes.POST("", searchHandler.Search)                         // Full search with all options
+				searchRoutes.POST("/workspace", searchHandler.SearchWorkspace)      // Across user or org projects
+				searchRoutes.GET("/quick", searchHandler.QuickSearch)               // Quick search for autocomplete
+				searchRoutes.GET("/symbols", searchHandler.SearchSymbols)           // Symbol search (functions, classes)
+				searchRoutes.GET("/files", searchHandler.SearchFiles)               // File name search
+				searchRoutes.POST("/replace", searchHandler.SearchAndReplace)       // Search & replace
+				searchRoutes.POST("/replace/preview", searchHandler.PreviewReplace) // Per-match before/after
+				searchRoutes.POST("/replace/apply", searchHandler.ApplyReplace)     // Apply selected matches
+				searchRoutes.POST("/replace/undo", searchHandler.UndoReplace)       // Undo by token
+				searchRoutes.GET("/history", searchHandler.GetSearchHistory)        // Search history
+				searchRoutes.DELETE("/history", searchHandler.ClearSearchHistory)   // Clear history
+				searchRoutes.GET("/index/stats", searchHandler.GetIndexStats)       // Index coverage and lag
 			}
 
 			// Live Preview endpoints
@@ -1778,16 +2751,24 @@ func setupRoutes(
 				previewRoutes.GET("/url/:projectId", previewHandler.GetPreviewURL)                     // Get preview URL
 
 				// Bundler endpoints
-				previewRoutes.POST("/build", previewHandler.BuildProject)                       // Bundle project
-				previewRoutes.GET("/bundler/status", previewHandler.GetBundlerStatus)           // Bundler availability
-				previewRoutes.POST("/bundler/invalidate", previewHandler.InvalidateBundleCache) // Invalidate cache
+				previewRoutes.POST("/build", previewHandler.BuildProject)                         // Bundle project
+				previewRoutes.GET("/bundler/status", previewHandler.GetBundlerStatus)             // Bundler availability
+				previewRoutes.POST("/bundler/invalidate", previewHandler.InvalidateBundleCache)   // Invalidate cache
+				previewRoutes.GET("/bundler/timings/:projectId", previewHandler.GetBundleTimings) // Bundle timings
+
+				// Runtime diagnostics endpoints
+				previewRoutes.GET("/diagnostics/:projectId", previewHandler.GetPreviewDiagnostics)                                                                      // Captured errors
+				previewRoutes.DELETE("/diagnostics/:projectId", previewHandler.ClearPreviewDiagnostics)                                                                 // Clear errors
+				previewRoutes.POST("/diagnostics/:projectId/:diagnosticId/solve", quotaChecker.CheckAIQuota(), budgetMiddleware, previewHandler.SolvePreviewDiagnostic) // Solver fix
 
 				// Backend server endpoints
-				previewRoutes.POST("/server/start", previewHandler.StartServer)                // Start backend server
-				previewRoutes.POST("/server/stop", previewHandler.StopServer)                  // Stop backend server
-				previewRoutes.GET("/server/status/:projectId", previewHandler.GetServerStatus) // Server status
-				previewRoutes.GET("/server/logs/:projectId", previewHandler.GetServerLogs)     // Server logs
-				previewRoutes.GET("/server/detect/:projectId", previewHandler.DetectServer)    // Detect backend
+				previewRoutes.POST("/server/start", previewHandler.StartServer)                 // Start backend server
+				previewRoutes.POST("/server/stop", previewHandler.StopServer)                   // Stop backend server
+				previewRoutes.GET("/server/status/:projectId", previewHandler.GetServerStatus)  // Server status
+				previewRoutes.GET("/server/logs/:projectId", previewHandler.GetServerLogs)      // Server logs
+				previewRoutes.GET("/server/detect/:projectId", previewHandler.DetectServer)     // Detect backend
+				previewRoutes.GET("/server/ports/:projectId", previewHandler.GetServerPorts)    // Listening ports
+				previewRoutes.PUT("/server/ports/:projectId", previewHandler.UpdateServerPorts) // Port mapping
 
 				// Docker sandbox endpoint
 				previewRoutes.GET("/docker/status", previewHandler.GetDockerStatus) // Docker availability
@@ -1796,21 +2777,32 @@ func setupRoutes(
 			// Git Integration endpoints
 			gitRoutes := protected.Group("/git")
 			{
-				gitRoutes.POST("/connect", gitHandler.ConnectRepository)                  // Connect to repo
-				gitRoutes.GET("/repo/:projectId", gitHandler.GetRepository)               // Get repo info
-				gitRoutes.DELETE("/repo/:projectId", gitHandler.DisconnectRepository)     // Disconnect
-				gitRoutes.GET("/branches/:projectId", gitHandler.GetBranches)             // List branches
-				gitRoutes.GET("/commits/:projectId", gitHandler.GetCommits)               // Get commits
-				gitRoutes.GET("/status/:projectId", gitHandler.GetStatus)                 // Working tree status
-				gitRoutes.POST("/commit", gitHandler.Commit)                              // Create commit
-				gitRoutes.POST("/push", gitHandler.Push)                                  // Push to remote
-				gitRoutes.POST("/pull", gitHandler.Pull)                                  // Pull from remote
-				gitRoutes.POST("/branch", gitHandler.CreateBranch)                        // Create branch
-				gitRoutes.POST("/checkout", gitHandler.SwitchBranch)                      // Switch branch
-				gitRoutes.GET("/pulls/:projectId", gitHandler.GetPullRequests)            // List PRs
-				gitRoutes.POST("/pulls", gitHandler.CreatePullRequest)                    // Create PR
-				gitRoutes.POST("/export", exportHandler.ExportToGitHub)                   // Export project to GitHub
-				gitRoutes.GET("/export/status/:projectId", exportHandler.GetExportStatus) // Check export status
+				gitRoutes.POST("/connect", gitHandler.ConnectRepository)                                                                             // Connect to repo
+				gitRoutes.GET("/repo/:projectId", gitHandler.GetRepository)                                                                          // Get repo info
+				gitRoutes.DELETE("/repo/:projectId", gitHandler.DisconnectRepository)                                                                // Disconnect
+				gitRoutes.GET("/branches/:projectId", gitHandler.GetBranches)                                                                        // List branches
+				gitRoutes.GET("/commits/:projectId", gitHandler.GetCommits)                                                                          // Get commits
+				gitRoutes.GET("/status/:projectId", gitHandler.GetStatus)                                                                            // Working tree status
+				gitRoutes.POST("/commit", gitHandler.Commit)                                                                                         // Create commit
+				gitRoutes.POST("/push", gitHandler.Push)                                                                                             // Push to remote
+				gitRoutes.POST("/pull", gitHandler.Pull)                                                                                             // Pull from remote
+				gitRoutes.GET("/sync/:projectId", gitHandler.GetSyncStatus)                                                                          // Auto-sync status and conflicts
+				gitRoutes.PUT("/sync/:projectId", gitHandler.SetAutoSync)                                                                            // Toggle webhook auto-sync
+				gitRoutes.POST("/sync/:projectId", gitHandler.SyncNow)                                                                               // Conflict-aware sync now
+				gitRoutes.GET("/conflicts/:projectId", gitHandler.GetConflicts)                                                                      // Conflict hunks
+				gitRoutes.POST("/conflicts/:projectId/resolve", gitHandler.ResolveConflict)                                                          // ours/theirs/manual
+				gitRoutes.POST("/conflicts/:projectId/suggest", quotaChecker.CheckAIQuota(), budgetMiddleware, gitHandler.SuggestConflictResolution) // AI merge suggestion
+				gitRoutes.POST("/conflicts/:projectId/commit", gitHandler.CommitConflictResolution)                                                  // Commit the merge
+				gitRoutes.POST("/issues/:projectId/build", quotaChecker.CheckAIQuota(), budgetMiddleware, gitHandler.BuildFromIssue)                 // Build from a GitHub issue
+				gitRoutes.GET("/issues/:projectId/builds", gitHandler.ListIssueBuilds)                                                               // Issue build history
+				gitRoutes.GET("/signing/:projectId", gitHandler.GetCommitSigning)                                                                    // Commit signing key
+				gitRoutes.PUT("/signing/:projectId", gitHandler.SetCommitSigning)                                                                    // Toggle commit signing
+				gitRoutes.POST("/branch", gitHandler.CreateBranch)                                                                                   // Create branch
+				gitRoutes.POST("/checkout", gitHandler.SwitchBranch)                                                                                 // Switch branch
+				gitRoutes.GET("/pulls/:projectId", gitHandler.GetPullRequests)                                                                       // List PRs
+				gitRoutes.POST("/pulls", gitHandler.CreatePullRequest)                                                                               // Create PR
+				gitRoutes.POST("/export", exportHandler.ExportToGitHub)                                                                              // Export project to GitHub
+				gitRoutes.GET("/export/status/:projectId", exportHandler.GetExportStatus)                                                            // Check export status
 			}
 
 			// GitHub Repository Import Wizard (one-click import like replit.new/URL)
@@ -1847,22 +2839,26 @@ func setupRoutes(
 			// Code Execution endpoints (the core of cloud IDE) - with quota + budget enforcement
 			if executionHandler != nil {
 				execute := protected.Group("/execute")
-				execute.Use(quotaChecker.CheckExecutionQuota(1)) // Check execution minutes quota
+				execute.Use(quotaChecker.CheckExecutionQuota(1)) // Pre-check; handlers reserve the full timeout
 				execute.Use(budgetMiddleware)                    // Enforce budget caps
+				execute.Use(abuseGate)                           // Block suspended/throttled accounts
 				{
-					execute.POST("", executionHandler.ExecuteCode)                           // Execute code snippet
-					execute.POST("/file", executionHandler.ExecuteFile)                      // Execute a file
-					execute.POST("/project", executionHandler.ExecuteProject)                // Execute entire project
-					execute.GET("/languages", executionHandler.GetLanguages)                 // Get supported languages
-					execute.GET("/:id", executionHandler.GetExecution)                       // Get execution details
-					execute.GET("/history", executionHandler.GetExecutionHistory)            // Get execution history
-					execute.POST("/:id/stop", executionHandler.StopExecution)                // Stop running execution
-					execute.GET("/stats", executionHandler.GetExecutionStats)                // Get execution statistics
-					execute.GET("/sandbox/status", executionHandler.GetSandboxStatusHandler) // Get sandbox security status
+					execute.POST("", executionHandler.ExecuteCode)                                 // Execute code snippet
+					execute.POST("/file", executionHandler.ExecuteFile)                            // Execute a file
+					execute.POST("/project", executionHandler.ExecuteProject)                      // Execute entire project
+					execute.GET("/languages", executionHandler.GetLanguages)                       // Get supported languages
+					execute.GET("/:id", executionHandler.GetExecution)                             // Get execution details
+					execute.GET("/history", executionHandler.GetExecutionHistory)                  // Get execution history
+					execute.POST("/:id/stop", executionHandler.StopExecution)                      // Stop running execution
+					execute.GET("/:id/artifacts", executionHandler.GetExecutionArtifacts)          // Files the run wrote
+					execute.POST("/:id/artifacts/apply", executionHandler.ApplyExecutionArtifacts) // Save run files into the project
+					execute.GET("/stats", executionHandler.GetExecutionStats)                      // Get execution statistics
+					execute.GET("/sandbox/status", executionHandler.GetSandboxStatusHandler)       // Get sandbox security status
 				}
 
 				// Terminal endpoints (interactive shell with full PTY support)
 				terminal := protected.Group("/terminal")
+				terminal.Use(abuseGate) // Block suspended/throttled accounts
 				{
 					terminal.POST("/sessions", executionHandler.CreateTerminalSession)            // Create new terminal
 					terminal.GET("/sessions", executionHandler.ListTerminalSessions)              // List all terminals
@@ -1877,18 +2873,30 @@ func setupRoutes(
 				registerUnavailableRoutes(protected, "/terminal", "Terminal sessions are currently unavailable")
 			}
 
+			// Notebook endpoints (persistent kernels draw on the execution quota)
+			if notebookHandler != nil {
+				notebooks := protected.Group("")
+				notebooks.Use(budgetMiddleware)
+				notebooks.Use(abuseGate)
+				notebookHandler.RegisterRoutes(notebooks, quotaChecker.CheckExecutionQuota(1))
+			} else {
+				registerUnavailableRoutes(protected, "/notebooks", "Notebooks are currently unavailable")
+			}
+
 			// One-Click Deployment endpoints (Vercel, Netlify, Render)
 			deployRoutes := protected.Group("/deploy")
 			{
-				deployRoutes.POST("", deployHandler.StartDeployment)                                  // Start deployment
-				deployRoutes.GET("/:id", deployHandler.GetDeployment)                                 // Get deployment details
-				deployRoutes.GET("/:id/status", deployHandler.GetDeploymentStatus)                    // Get status only
-				deployRoutes.GET("/:id/logs", deployHandler.GetDeploymentLogs)                        // Get deployment logs
-				deployRoutes.DELETE("/:id", deployHandler.CancelDeployment)                           // Cancel deployment
-				deployRoutes.POST("/:id/redeploy", deployHandler.Redeploy)                            // Redeploy
-				deployRoutes.GET("/providers", deployHandler.GetProviders)                            // List providers
-				deployRoutes.GET("/projects/:projectId/history", deployHandler.GetProjectDeployments) // Deployment history
-				deployRoutes.GET("/projects/:projectId/latest", deployHandler.GetLatestDeployment)    // Latest deployment
+				deployRoutes.POST("", deployHandler.StartDeployment)                                    // Start deployment
+				deployRoutes.GET("/:id", deployHandler.GetDeployment)                                   // Get deployment details
+				deployRoutes.GET("/:id/status", deployHandler.GetDeploymentStatus)                      // Get status only
+				deployRoutes.GET("/:id/logs", deployHandler.GetDeploymentLogs)                          // Get deployment logs
+				deployRoutes.GET("/:id/provenance", deployHandler.GetDeploymentProvenance)              // SLSA provenance attestation
+				deployRoutes.DELETE("/:id", deployHandler.CancelDeployment)                             // Cancel deployment
+				deployRoutes.POST("/:id/redeploy", deployHandler.Redeploy)                              // Redeploy
+				deployRoutes.GET("/providers", deployHandler.GetProviders)                              // List providers
+				deployRoutes.GET("/projects/:projectId/history", deployHandler.GetProjectDeployments)   // Deployment history
+				deployRoutes.GET("/projects/:projectId/latest", deployHandler.GetLatestDeployment)      // Latest deployment
+				deployRoutes.GET("/projects/:projectId/estimate", deployHandler.EstimateDeploymentCost) // Monthly cost per target
 			}
 
 			// Package Management endpoints (NPM, PyPI, Go Modules)
@@ -1901,7 +2909,22 @@ func setupRoutes(
 			communityHandler.RegisterProtectedRoutes(protected)
 
 			// Native Hosting endpoints (.apex.app)
-			hostingHandler.RegisterHostingRoutes(protected)
+			hostingHandler.RegisterHostingRoutes(protected, quotaChecker.CheckAIQuota(), budgetMiddleware)
+
+			// Deployment pipeline: staging/production environments and promotion
+			pipelineHandler.RegisterRoutes(protected)
+
+			// Dockerfile generation verified by a sandbox image build
+			dockerizeHandler.RegisterRoutes(protected)
+
+			// apex.lock environment verification and regeneration
+			environmentLockHandler.RegisterRoutes(protected)
+
+			// Environment variables the code reads but no environment sets
+			envCheckHandler.RegisterRoutes(protected)
+
+			// Project service tokens for scheduled runs and hosted apps
+			projectTokenHandler.RegisterRoutes(protected)
 
 			// Managed Database endpoints
 			if databaseHandler != nil {
@@ -1965,7 +2988,18 @@ func setupRoutes(
 				admin.GET("/stats", server.AdminGetSystemStats)
 				admin.POST("/rotate-secrets", rotationHandler.RotateSecrets)
 				admin.GET("/validate-secrets", rotationHandler.ValidateSecrets)
+				admin.POST("/secret-rotations", rotationHandler.StartSecretRotation)
+				admin.GET("/secret-rotations", rotationHandler.ListSecretRotations)
+				admin.GET("/secret-rotations/:id", rotationHandler.GetSecretRotation)
+				admin.POST("/secret-rotations/:id/cancel", rotationHandler.CancelSecretRotation)
 				buildHandler.RegisterArchitectureAdminRoutes(admin)
+				agentMarketHandler.RegisterAdminRoutes(admin)
+				warehouseHandler.RegisterAdminRoutes(admin)
+				abuseHandler.RegisterAdminRoutes(admin)
+				usageHandler.RegisterAdminRoutes(admin)
+				retentionHandler.RegisterAdminRoutes(admin)
+				searchHandler.RegisterAdminRoutes(admin)
+				buildFailureHandler.RegisterAdminRoutes(admin)
 			}
 		}
 	}
@@ -1993,6 +3027,11 @@ func setupRoutes(
 	// WebSocket endpoint for debugging sessions
 	router.GET("/ws/debug/:sessionId", debuggingHandler.HandleDebugWebSocket)
 
+	// WebSocket endpoint for notebook kernel output
+	if notebookHandler != nil {
+		router.GET("/ws/notebook/:kernelId", notebookHandler.HandleNotebookWebSocket)
+	}
+
 	// WebSocket endpoint for deployment log streaming
 	router.GET("/ws/deploy/:deploymentId", hostingHandler.HandleDeploymentWebSocket)
 
@@ -2093,6 +3132,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
 		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
@@ -2170,6 +3218,50 @@ func getOllamaStatus(baseURL string) string {
 	return "❌ Disabled (set OLLAMA_BASE_URL)"
 }
 
+// buildPauserBridge adapts agents.AgentManager to budget.BuildPauser so the
+// spend anomaly detector can pause builds without importing agents.
+type buildPauserBridge struct {
+	manager *agents.AgentManager
+}
+
+func (b *buildPauserBridge) PauseBuild(buildID, reason string) error {
+	_, err := b.manager.PauseBuild(buildID, reason)
+	return err
+}
+
+func (b *buildPauserBridge) ResumeBuild(buildID, reason string) error {
+	_, err := b.manager.ResumeBuild(buildID, reason)
+	return err
+}
+
+// projectQuotaBridge adapts usage.Tracker to ownership.UserProjectQuota so a
+// transfer recipient's plan is checked without importing middleware.
+type projectQuotaBridge struct {
+	tracker *usage.Tracker
+	db      *gorm.DB
+}
+
+func (b *projectQuotaBridge) AllowProject(ctx context.Context, userID uint) (bool, error) {
+	var user models.User
+	if err := b.db.WithContext(ctx).Select("id", "subscription_type", "subscription_status", "bypass_billing", "is_admin", "is_super_admin").
+		First(&user, userID).Error; err != nil {
+		return false, err
+	}
+	if user.BypassBilling || user.IsAdmin || user.IsSuperAdmin {
+		return true, nil
+	}
+	plan := usage.PlanType(user.SubscriptionType)
+	switch user.SubscriptionStatus {
+	case "past_due", "canceled", "inactive":
+		plan = usage.PlanFree
+	}
+	if plan == "" {
+		plan = usage.PlanFree
+	}
+	allowed, _, _, err := b.tracker.CheckQuota(ctx, userID, plan, usage.UsageProjects, 1)
+	return allowed, err
+}
+
 // previewVerifierBridge adapts preview.Verifier to the agents.BuildPreviewVerifier
 // interface without creating a circular import between the two packages.
 type previewVerifierBridge struct {
@@ -2201,5 +3293,84 @@ func (b *previewVerifierBridge) VerifyBuildFiles(
 		CanaryPostInteractionChecked: res.CanaryPostInteractionChecked,
 		CanaryPostInteractionHealthy: res.CanaryPostInteractionHealthy,
 		VisionSeverity:               res.VisionSeverity,
+		SmokeStepsRun:                res.SmokeStepsRun,
+	}
+}
+
+// activityBridge adds finished builds and deployments to the project
+// activity feed without importing activity into agents, deploy or hosting.
+type activityBridge struct {
+	service *activity.Service
+}
+
+func (b *activityBridge) BuildFinished(projectID, userID uint, buildID, status, description string, at time.Time) {
+	summary := "build " + status
+	if description != "" {
+		summary = fmt.Sprintf("build %s: %s", status, truncateActivityText(description, 200))
+	}
+	b.record(activity.Event{
+		ProjectID:  projectID,
+		OccurredAt: at,
+		ActorID:    userID,
+		Kind:       activity.KindBuild,
+		Action:     status,
+		Summary:    summary,
+		Ref:        fmt.Sprintf("build:%s:%s", buildID, status),
+		Metadata:   map[string]any{"build_id": buildID},
+	})
+}
+
+func (b *activityBridge) DeploymentStatusChanged(deployment *deploy.Deployment) {
+	switch deployment.Status {
+	case deploy.StatusLive, deploy.StatusFailed, deploy.StatusCancelled:
+	default:
+		return
+	}
+	status := string(deployment.Status)
+	b.record(activity.Event{
+		ProjectID: deployment.ProjectID,
+		ActorID:   deployment.UserID,
+		Kind:      activity.KindDeployment,
+		Action:    status,
+		Target:    deployment.URL,
+		Summary:   fmt.Sprintf("%s deployment to %s %s", deployment.Environment, deployment.Provider, status),
+		Ref:       fmt.Sprintf("deploy:%s:%s", deployment.ID, status),
+		Metadata:  map[string]any{"deployment_id": deployment.ID, "provider": string(deployment.Provider)},
+	})
+}
+
+func (b *activityBridge) NativeDeploymentStatusChanged(deployment *hosting.NativeDeployment) {
+	action := ""
+	switch deployment.Status {
+	case hosting.StatusRunning:
+		action = "live"
+	case hosting.StatusFailed:
+		action = "failed"
+	default:
+		return
+	}
+	b.record(activity.Event{
+		ProjectID: deployment.ProjectID,
+		ActorID:   deployment.UserID,
+		Kind:      activity.KindDeployment,
+		Action:    action,
+		Target:    deployment.URL,
+		Summary:   fmt.Sprintf("APEX hosting deployment %s", action),
+		Ref:       fmt.Sprintf("hosting:%s:%s", deployment.ID, action),
+		Metadata:  map[string]any{"deployment_id": deployment.ID, "provider": "apex"},
+	})
+}
+
+func (b *activityBridge) record(event activity.Event) {
+	if err := b.service.Record(context.Background(), event); err != nil {
+		log.Printf("activity: failed to record %s for project %d: %v", event.Kind, event.ProjectID, err)
+	}
+}
+
+func truncateActivityText(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= max {
+		return text
 	}
+	return strings.TrimSpace(text[:max]) + "..."
 }
@@ -0,0 +1,79 @@
+package abuse
+
+import "strings"
+
+// disposableDomains are throwaway inbox providers. Accounts on them can use
+// the editor and AI features but not code execution.
+var disposableDomains = map[string]bool{
+	"10minutemail.com":       true,
+	"10minutemail.net":       true,
+	"20minutemail.com":       true,
+	"33mail.com":             true,
+	"dispostable.com":        true,
+	"dropmail.me":            true,
+	"emailondeck.com":        true,
+	"fakeinbox.com":          true,
+	"fakemail.net":           true,
+	"getairmail.com":         true,
+	"getnada.com":            true,
+	"guerrillamail.biz":      true,
+	"guerrillamail.com":      true,
+	"guerrillamail.de":       true,
+	"guerrillamail.info":     true,
+	"guerrillamail.net":      true,
+	"guerrillamail.org":      true,
+	"guerrillamailblock.com": true,
+	"harakirimail.com":       true,
+	"inboxkitten.com":        true,
+	"mail.tm":                true,
+	"mailcatch.com":          true,
+	"maildrop.cc":            true,
+	"mailinator.com":         true,
+	"mailinator.net":         true,
+	"mailnesia.com":          true,
+	"mailpoof.com":           true,
+	"mintemail.com":          true,
+	"moakt.com":              true,
+	"mohmal.com":             true,
+	"mytemp.email":           true,
+	"nada.email":             true,
+	"sharklasers.com":        true,
+	"spam4.me":               true,
+	"spamgourmet.com":        true,
+	"temp-mail.io":           true,
+	"temp-mail.org":          true,
+	"tempail.com":            true,
+	"tempinbox.com":          true,
+	"tempmail.dev":           true,
+	"tempmail.net":           true,
+	"tempmailo.com":          true,
+	"tempr.email":            true,
+	"throwawaymail.com":      true,
+	"trashmail.com":          true,
+	"trashmail.de":           true,
+	"trashmail.net":          true,
+	"yopmail.com":            true,
+	"yopmail.fr":             true,
+	"yopmail.net":            true,
+}
+
+// IsDisposableEmail reports whether the email's domain, or a parent of it,
+// is a disposable provider. extra holds additional lowercase domains.
+func IsDisposableEmail(email string, extra map[string]bool) bool {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return false
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+	for domain != "" {
+		if disposableDomains[domain] || extra[domain] {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package abuse
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A pattern scores code or output that matches it
+type pattern struct {
+	kind  string
+	score int
+	re    *regexp.Regexp
+	// blocks refuses the run outright instead of only recording a signal
+	blocks bool
+}
+
+var codePatterns = []pattern{
+	{
+		kind:   SignalMinerBinary,
+		score:  60,
+		blocks: true,
+		re:     regexp.MustCompile(`(?i)\b(xmrig|xmr-stak|cpuminer(-multi|-opt)?|minerd|ethminer|nbminer|t-rex|lolminer|phoenixminer|ccminer|bfgminer|cgminer|srbminer(-multi)?|teamredminer|gminer|nanominer|xmrig-proxy)\b`),
+	},
+	{
+		kind:   SignalMiningPool,
+		score:  60,
+		blocks: true,
+		re:     regexp.MustCompile(`(?i)stratum\+(tcp|ssl|tls)://|\b(minexmr\.com|supportxmr\.com|moneroocean\.stream|nanopool\.org|2miners\.com|f2pool\.com|hashvault\.pro|c3pool\.com|herominers\.com|nicehash\.com|unmineable\.com|ethermine\.org|miningpoolhub\.com)\b`),
+	},
+	{
+		kind:  SignalOutboundSMTP,
+		score: 25,
+		re:    regexp.MustCompile(`(?i)smtplib\.SMTP(_SSL)?\s*\(|nodemailer\.createTransport|"net/smtp"|smtp\.SendMail|\bPHPMailer\b|\bswaks\b|\bsendmail\s+-t\b|smtp[\w.-]*:(25|465|587)\b|\bport\s*[:=]\s*(25|465|587)\b`),
+	},
+}
+
+var outputPatterns = []pattern{
+	{
+		kind:  SignalMinerOutput,
+		score: 80,
+		re:    regexp.MustCompile(`(?i)\baccepted \(\d+/\d+\)|\bnew job from\b|\bspeed 10s/60s/15m\b|\bshare accepted\b|\buse pool\b.*stratum|\bhashrate\b.*\b[kmg]?h/s\b`),
+	},
+}
+
+// Match is a pattern hit with the text around it
+type Match struct {
+	Kind     string
+	Score    int
+	Blocks   bool
+	Evidence string
+}
+
+func scan(patterns []pattern, text string) []Match {
+	var matches []Match
+	for _, p := range patterns {
+		loc := p.re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		matches = append(matches, Match{
+			Kind:     p.kind,
+			Score:    p.score,
+			Blocks:   p.blocks,
+			Evidence: excerpt(text, loc[0], loc[1]),
+		})
+	}
+	return matches
+}
+
+// ScanCode returns the abuse patterns found in code or a command line
+func ScanCode(code string) []Match {
+	return scan(codePatterns, code)
+}
+
+// ScanOutput returns the abuse patterns found in execution output
+func ScanOutput(output string) []Match {
+	return scan(outputPatterns, output)
+}
+
+// excerpt returns the match with a little context on the same line
+func excerpt(text string, start, end int) string {
+	from := start - 60
+	if from < 0 {
+		from = 0
+	}
+	if i := strings.LastIndexByte(text[from:start], '\n'); i >= 0 {
+		from += i + 1
+	}
+	to := end + 60
+	if to > len(text) {
+		to = len(text)
+	}
+	if i := strings.IndexByte(text[end:to], '\n'); i >= 0 {
+		to = end + i
+	}
+	out := strings.TrimSpace(text[from:to])
+	if len(out) > 200 {
+		out = out[:200]
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+// Package abuse detects compute abuse in user code execution, such as
+// cryptocurrency mining and outbound spam, and throttles or suspends
+// execution for the accounts involved pending admin review.
+package abuse
+
+import "time"
+
+// Signal kinds
+const (
+	SignalMinerBinary  = "miner_binary"
+	SignalMiningPool   = "mining_pool"
+	SignalMinerOutput  = "miner_output"
+	SignalOutboundSMTP = "outbound_smtp"
+	SignalSustainedCPU = "sustained_cpu"
+	SignalCPUBudget    = "cpu_budget"
+)
+
+// Case statuses. Review, throttled, suspended and appealed cases are open.
+const (
+	CaseReview    = "review"
+	CaseThrottled = "throttled"
+	CaseSuspended = "suspended"
+	CaseAppealed  = "appealed"
+	CaseCleared   = "cleared"
+)
+
+// Enforcement levels
+const (
+	EnforceNone     = "none"
+	EnforceThrottle = "throttle"
+	EnforceSuspend  = "suspend"
+)
+
+// Decision codes returned when execution is refused
+const (
+	CodeSuspended       = "execution_suspended"
+	CodeThrottled       = "execution_throttled"
+	CodeBlockedContent  = "execution_blocked"
+	CodeDisposableEmail = "disposable_email_blocked"
+)
+
+var openStatuses = []string{CaseReview, CaseThrottled, CaseSuspended, CaseAppealed}
+
+// Signal is one piece of evidence of abuse from a single execution
+type Signal struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	CaseID      *uint     `json:"case_id,omitempty" gorm:"index"`
+	ExecutionID string    `json:"execution_id,omitempty" gorm:"size:64"`
+	Kind        string    `json:"kind" gorm:"size:32;not null"`
+	Score       int       `json:"score" gorm:"not null"`
+	Evidence    string    `json:"evidence" gorm:"size:512"`
+}
+
+// TableName specifies the table name for Signal
+func (Signal) TableName() string {
+	return "abuse_signals"
+}
+
+// Case is the review record for a user whose signals crossed a threshold.
+// A user has at most one open case.
+type Case struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint   `json:"user_id" gorm:"not null;index"`
+	Status      string `json:"status" gorm:"size:16;not null;index"`
+	Enforcement string `json:"enforcement" gorm:"size:16;not null"`
+	Score       int    `json:"score"`
+	Reason      string `json:"reason" gorm:"size:512"`
+	FreeTier    bool   `json:"free_tier"`
+
+	AppealMessage string     `json:"appeal_message,omitempty" gorm:"type:text"`
+	AppealedAt    *time.Time `json:"appealed_at,omitempty"`
+
+	ReviewedBy *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote string     `json:"review_note,omitempty" gorm:"type:text"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"`
+}
+
+// TableName specifies the table name for Case
+func (Case) TableName() string {
+	return "abuse_cases"
+}
+
+// Decision is the outcome of admitting or screening an execution
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Code    string `json:"code,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	CaseID  uint   `json:"case_id,omitempty"`
+	// MaxTimeout caps the run time of a throttled user's executions
+	MaxTimeout time.Duration `json:"-"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+// Run is what the detector sees of a finished execution
+type Run struct {
+	ExecutionID string
+	Output      string
+	DurationMs  int64
+	CPUTimeMs   int64
+}
+
+// Config tunes detection and enforcement
+type Config struct {
+	// Signal scores within Window are summed per user
+	Window        time.Duration
+	ThrottleScore int
+	SuspendScore  int
+
+	// Throttled users get ThrottledRunsPerHour executions of at most
+	// ThrottledTimeout each
+	ThrottledRunsPerHour int
+	ThrottledTimeout     time.Duration
+
+	// A run is CPU bound when CPU time is at least SustainedCPURatio of
+	// wall time for at least SustainedCPUMin
+	SustainedCPURatio float64
+	SustainedCPUMin   time.Duration
+	// CPUMinutesPerHour is the free-tier CPU time a user may burn in an
+	// hour before it counts as a signal
+	CPUMinutesPerHour float64
+
+	// DisposableDomains extends the built-in disposable email list
+	DisposableDomains []string
+}
+
+// DefaultConfig returns the default thresholds
+func DefaultConfig() Config {
+	return Config{
+		Window:               24 * time.Hour,
+		ThrottleScore:        40,
+		SuspendScore:         80,
+		ThrottledRunsPerHour: 10,
+		ThrottledTimeout:     10 * time.Second,
+		SustainedCPURatio:    0.9,
+		SustainedCPUMin:      20 * time.Second,
+		CPUMinutesPerHour:    20,
+	}
+}
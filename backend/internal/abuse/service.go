@@ -0,0 +1,450 @@
+package abuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoCase          = errors.New("no enforcement to appeal")
+	ErrAlreadyAppealed = errors.New("this case has already been appealed")
+	ErrCaseClosed      = errors.New("case is closed")
+	ErrInvalidAction   = errors.New("action must be clear, throttle or suspend")
+)
+
+// Service records abuse signals, escalates them into cases and decides
+// whether a user may start an execution
+type Service struct {
+	db         *gorm.DB
+	cfg        Config
+	disposable map[string]bool
+	now        func() time.Time
+}
+
+// NewService creates a new abuse detection service
+func NewService(db *gorm.DB, cfg Config) *Service {
+	disposable := map[string]bool{}
+	for _, domain := range cfg.DisposableDomains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			disposable[domain] = true
+		}
+	}
+	return &Service{db: db, cfg: cfg, disposable: disposable, now: time.Now}
+}
+
+// AutoMigrate creates the abuse tables
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&Signal{}, &Case{})
+}
+
+type account struct {
+	ID               uint
+	Email            string
+	SubscriptionType string
+	IsAdmin          bool
+	IsSuperAdmin     bool
+}
+
+func (s *Service) account(ctx context.Context, userID uint) (*account, error) {
+	var user account
+	err := s.db.WithContext(ctx).Model(&models.User{}).
+		Select("id, email, subscription_type, is_admin, is_super_admin").
+		Where("id = ?", userID).Take(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (a *account) freeTier() bool {
+	return a.SubscriptionType == "" || a.SubscriptionType == "free"
+}
+
+// OpenCase returns the user's open case, or nil
+func (s *Service) OpenCase(ctx context.Context, userID uint) (*Case, error) {
+	var c Case
+	err := s.db.WithContext(ctx).Where("user_id = ? AND status IN ?", userID, openStatuses).
+		Order("id DESC").First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Admit decides whether the user may start an execution. Errors are
+// logged and admit the run, so an outage here does not stop execution.
+func (s *Service) Admit(ctx context.Context, userID uint) Decision {
+	user, err := s.account(ctx, userID)
+	if err != nil {
+		log.Printf("[abuse] admit user %d: %v", userID, err)
+		return Decision{Allowed: true}
+	}
+	if user.IsAdmin || user.IsSuperAdmin {
+		return Decision{Allowed: true}
+	}
+	if IsDisposableEmail(user.Email, s.disposable) {
+		return Decision{
+			Code:   CodeDisposableEmail,
+			Reason: "Code execution is not available for disposable email addresses. Change your account email to a permanent address to run code.",
+		}
+	}
+
+	open, err := s.OpenCase(ctx, userID)
+	if err != nil {
+		log.Printf("[abuse] admit user %d: %v", userID, err)
+		return Decision{Allowed: true}
+	}
+	if open == nil {
+		return Decision{Allowed: true}
+	}
+	switch open.Enforcement {
+	case EnforceSuspend:
+		return Decision{
+			Code:   CodeSuspended,
+			Reason: "Code execution is suspended on this account after automated abuse detection: " + open.Reason,
+			CaseID: open.ID,
+		}
+	case EnforceThrottle:
+		var recent int64
+		since := s.now().Add(-time.Hour)
+		if err := s.db.WithContext(ctx).Model(&models.Execution{}).
+			Where("user_id = ? AND created_at >= ?", userID, since).Count(&recent).Error; err != nil {
+			log.Printf("[abuse] throttle count for user %d: %v", userID, err)
+		}
+		if int(recent) >= s.cfg.ThrottledRunsPerHour {
+			return Decision{
+				Code:       CodeThrottled,
+				Reason:     fmt.Sprintf("Code execution on this account is limited to %d runs per hour while it is under review", s.cfg.ThrottledRunsPerHour),
+				CaseID:     open.ID,
+				RetryAfter: 10 * time.Minute,
+			}
+		}
+		return Decision{Allowed: true, CaseID: open.ID, MaxTimeout: s.cfg.ThrottledTimeout}
+	}
+	return Decision{Allowed: true, CaseID: open.ID}
+}
+
+// Screen checks code or a command line before it runs. Miner binaries and
+// mining pools refuse the run; every match is recorded as a signal.
+func (s *Service) Screen(ctx context.Context, userID uint, executionID string, sources ...string) Decision {
+	var matches []Match
+	seen := map[string]bool{}
+	for _, source := range sources {
+		for _, m := range ScanCode(source) {
+			if !seen[m.Kind] {
+				seen[m.Kind] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return Decision{Allowed: true}
+	}
+
+	decision := Decision{Allowed: true}
+	for _, m := range matches {
+		if m.Blocks {
+			decision = Decision{
+				Code:   CodeBlockedContent,
+				Reason: "This code looks like cryptocurrency mining, which is not allowed on APEX.BUILD (" + m.Kind + ")",
+			}
+		}
+	}
+	c, err := s.record(ctx, userID, executionID, matches)
+	if err != nil {
+		log.Printf("[abuse] record signals for user %d: %v", userID, err)
+	}
+	if c != nil {
+		decision.CaseID = c.ID
+		if c.Enforcement == EnforceSuspend && decision.Allowed {
+			decision = Decision{Code: CodeSuspended, Reason: "Code execution is suspended on this account: " + c.Reason, CaseID: c.ID}
+		}
+	}
+	return decision
+}
+
+// Observe reviews a finished execution for miner output and sustained CPU
+// use and escalates the user's case when needed
+func (s *Service) Observe(ctx context.Context, userID uint, run Run) {
+	matches := ScanOutput(run.Output)
+
+	if run.DurationMs >= s.cfg.SustainedCPUMin.Milliseconds() && run.DurationMs > 0 &&
+		float64(run.CPUTimeMs)/float64(run.DurationMs) >= s.cfg.SustainedCPURatio {
+		matches = append(matches, Match{
+			Kind:     SignalSustainedCPU,
+			Score:    15,
+			Evidence: fmt.Sprintf("cpu %dms over %dms wall time", run.CPUTimeMs, run.DurationMs),
+		})
+	}
+
+	if user, err := s.account(ctx, userID); err == nil && user.freeTier() && s.cfg.CPUMinutesPerHour > 0 {
+		var cpuMs int64
+		since := s.now().Add(-time.Hour)
+		s.db.WithContext(ctx).Model(&models.Execution{}).
+			Where("user_id = ? AND created_at >= ?", userID, since).
+			Select("COALESCE(SUM(cpu_time), 0)").Scan(&cpuMs)
+		if minutes := float64(cpuMs) / 60000; minutes >= s.cfg.CPUMinutesPerHour {
+			var already int64
+			s.db.WithContext(ctx).Model(&Signal{}).
+				Where("user_id = ? AND kind = ? AND created_at >= ?", userID, SignalCPUBudget, since).Count(&already)
+			if already == 0 {
+				matches = append(matches, Match{
+					Kind:     SignalCPUBudget,
+					Score:    40,
+					Evidence: fmt.Sprintf("%.1f CPU minutes in the last hour", minutes),
+				})
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return
+	}
+	if _, err := s.record(ctx, userID, run.ExecutionID, matches); err != nil {
+		log.Printf("[abuse] record signals for user %d: %v", userID, err)
+	}
+}
+
+// record stores signals and opens or escalates the user's case. Free-tier
+// accounts are throttled or suspended automatically; paid accounts are
+// only queued for review.
+func (s *Service) record(ctx context.Context, userID uint, executionID string, matches []Match) (*Case, error) {
+	user, err := s.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var result *Case
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		signals := make([]Signal, 0, len(matches))
+		for _, m := range matches {
+			signals = append(signals, Signal{
+				CreatedAt:   now,
+				UserID:      userID,
+				ExecutionID: executionID,
+				Kind:        m.Kind,
+				Score:       m.Score,
+				Evidence:    m.Evidence,
+			})
+		}
+		if err := tx.Create(&signals).Error; err != nil {
+			return err
+		}
+
+		var open Case
+		if err := tx.Where("user_id = ? AND status IN ?", userID, openStatuses).Order("id DESC").
+			Limit(1).Find(&open).Error; err != nil {
+			return err
+		}
+
+		// Signals before the last cleared case, or before an admin last
+		// reviewed the open one, no longer count
+		since := now.Add(-s.cfg.Window)
+		var cleared Case
+		if err := tx.Where("user_id = ? AND status = ?", userID, CaseCleared).Order("closed_at DESC").
+			Limit(1).Find(&cleared).Error; err != nil {
+			return err
+		}
+		if cleared.ClosedAt != nil && cleared.ClosedAt.After(since) {
+			since = *cleared.ClosedAt
+		}
+		if open.ReviewedAt != nil && open.ReviewedAt.After(since) {
+			since = *open.ReviewedAt
+		}
+		var window []Signal
+		if err := tx.Where("user_id = ? AND created_at >= ?", userID, since).Find(&window).Error; err != nil {
+			return err
+		}
+		score := 0
+		kinds := map[string]bool{}
+		for _, sig := range window {
+			score += sig.Score
+			kinds[sig.Kind] = true
+		}
+		if score < s.cfg.ThrottleScore {
+			return nil
+		}
+
+		enforcement := EnforceThrottle
+		if score >= s.cfg.SuspendScore {
+			enforcement = EnforceSuspend
+		}
+		if !user.freeTier() || user.IsAdmin || user.IsSuperAdmin {
+			enforcement = EnforceNone
+		}
+
+		if open.ID == 0 {
+			open = Case{UserID: userID, Enforcement: EnforceNone, FreeTier: user.freeTier()}
+		}
+		open.Score = score
+		open.Reason = describe(kinds)
+		// Escalate only; admins lower enforcement
+		if rank(enforcement) > rank(open.Enforcement) {
+			open.Enforcement = enforcement
+		}
+		if open.Status != CaseAppealed {
+			open.Status = statusFor(open.Enforcement)
+		}
+		if err := tx.Save(&open).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, 0, len(window))
+		for _, sig := range window {
+			ids = append(ids, sig.ID)
+		}
+		if err := tx.Model(&Signal{}).Where("id IN ? AND case_id IS NULL", ids).Update("case_id", open.ID).Error; err != nil {
+			return err
+		}
+		result = &open
+		return nil
+	})
+	if err == nil && result != nil {
+		log.Printf("[abuse] case %d user=%d score=%d enforcement=%s reason=%s", result.ID, userID, result.Score, result.Enforcement, result.Reason)
+	}
+	return result, err
+}
+
+func rank(enforcement string) int {
+	switch enforcement {
+	case EnforceSuspend:
+		return 2
+	case EnforceThrottle:
+		return 1
+	}
+	return 0
+}
+
+func statusFor(enforcement string) string {
+	switch enforcement {
+	case EnforceSuspend:
+		return CaseSuspended
+	case EnforceThrottle:
+		return CaseThrottled
+	}
+	return CaseReview
+}
+
+var signalDescriptions = map[string]string{
+	SignalMinerBinary:  "cryptocurrency miner binary",
+	SignalMiningPool:   "mining pool connection",
+	SignalMinerOutput:  "mining output",
+	SignalOutboundSMTP: "outbound email (SMTP)",
+	SignalSustainedCPU: "sustained full CPU use",
+	SignalCPUBudget:    "excessive CPU time",
+}
+
+func describe(kinds map[string]bool) string {
+	parts := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		if d, ok := signalDescriptions[kind]; ok {
+			parts = append(parts, d)
+		} else {
+			parts = append(parts, kind)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// Appeal asks for review of the user's throttled or suspended case
+func (s *Service) Appeal(ctx context.Context, userID uint, message string) (*Case, error) {
+	open, err := s.OpenCase(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if open == nil || open.Enforcement == EnforceNone {
+		return nil, ErrNoCase
+	}
+	if open.AppealedAt != nil {
+		return nil, ErrAlreadyAppealed
+	}
+	now := s.now()
+	open.Status = CaseAppealed
+	open.AppealMessage = strings.TrimSpace(message)
+	open.AppealedAt = &now
+	if err := s.db.WithContext(ctx).Save(open).Error; err != nil {
+		return nil, err
+	}
+	return open, nil
+}
+
+// Resolve records an admin decision. clear closes the case and lifts
+// enforcement; throttle and suspend keep it open with that enforcement.
+func (s *Service) Resolve(ctx context.Context, caseID, adminID uint, action, note string) (*Case, error) {
+	var c Case
+	if err := s.db.WithContext(ctx).First(&c, caseID).Error; err != nil {
+		return nil, err
+	}
+	if c.Status == CaseCleared {
+		return nil, ErrCaseClosed
+	}
+	now := s.now()
+	switch action {
+	case "clear":
+		c.Status = CaseCleared
+		c.Enforcement = EnforceNone
+		c.ClosedAt = &now
+	case EnforceThrottle, EnforceSuspend:
+		c.Enforcement = action
+		c.Status = statusFor(action)
+	default:
+		return nil, ErrInvalidAction
+	}
+	c.ReviewedBy = &adminID
+	c.ReviewedAt = &now
+	c.ReviewNote = strings.TrimSpace(note)
+	if err := s.db.WithContext(ctx).Save(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// QueueItem is a case in the admin review queue with its account
+type QueueItem struct {
+	Case
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// Queue lists cases for review: appealed first, then by score. An empty
+// status lists every open case.
+func (s *Service) Queue(ctx context.Context, status string, limit int) ([]QueueItem, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := s.db.WithContext(ctx).Table("abuse_cases c").
+		Select("c.*, u.username, u.email").
+		Joins("LEFT JOIN users u ON u.id = c.user_id")
+	if status != "" {
+		query = query.Where("c.status = ?", status)
+	} else {
+		query = query.Where("c.status IN ?", openStatuses)
+	}
+	var items []QueueItem
+	err := query.Order(fmt.Sprintf("CASE WHEN c.status = '%s' THEN 0 ELSE 1 END, c.score DESC, c.id ASC", CaseAppealed)).
+		Limit(limit).Scan(&items).Error
+	return items, err
+}
+
+// CaseDetail returns a case and its signals
+func (s *Service) CaseDetail(ctx context.Context, caseID uint) (*Case, []Signal, error) {
+	var c Case
+	if err := s.db.WithContext(ctx).First(&c, caseID).Error; err != nil {
+		return nil, nil, err
+	}
+	var signals []Signal
+	err := s.db.WithContext(ctx).Where("case_id = ?", caseID).Order("id DESC").Limit(200).Find(&signals).Error
+	return &c, signals, err
+}
@@ -0,0 +1,206 @@
+package abuse
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Execution{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := NewService(db, DefaultConfig()).AutoMigrate(); err != nil {
+		t.Fatalf("migrate abuse: %v", err)
+	}
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, name, email, plan string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: email, PasswordHash: "x", SubscriptionType: plan}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestScanCodeFlagsMinersAndSMTP(t *testing.T) {
+	cases := map[string]string{
+		"./xmrig -o pool.example:3333":              SignalMinerBinary,
+		`pool = "stratum+tcp://eu.pool.example:80"`: SignalMiningPool,
+		"s = smtplib.SMTP('mail.example.com', 587)": SignalOutboundSMTP,
+	}
+	for code, kind := range cases {
+		matches := ScanCode(code)
+		if len(matches) == 0 || matches[0].Kind != kind {
+			t.Fatalf("ScanCode(%q) = %+v, want %s", code, matches, kind)
+		}
+	}
+	if matches := ScanCode("print('hello, miner of data')"); len(matches) != 0 {
+		t.Fatalf("benign code matched: %+v", matches)
+	}
+}
+
+func TestMinerCodeSuspendsFreeUserUntilCleared(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	user := createUser(t, db, "ada", "ada@example.com", "free")
+	admin := createUser(t, db, "root", "root@example.com", "owner")
+	service := NewService(db, DefaultConfig())
+
+	decision := service.Screen(ctx, user.ID, "exec-1", "import os\nos.system('./xmrig --donate-level 1')")
+	if decision.Allowed || decision.Code != CodeBlockedContent {
+		t.Fatalf("miner screen = %+v", decision)
+	}
+	// A second attempt crosses the suspend threshold
+	decision = service.Screen(ctx, user.ID, "exec-2", "./xmrig")
+	if decision.Allowed || decision.CaseID == 0 {
+		t.Fatalf("second miner screen = %+v", decision)
+	}
+	if admit := service.Admit(ctx, user.ID); admit.Allowed || admit.Code != CodeSuspended {
+		t.Fatalf("admit after suspension = %+v", admit)
+	}
+
+	appealed, err := service.Appeal(ctx, user.ID, "It was a benchmark")
+	if err != nil || appealed.Status != CaseAppealed {
+		t.Fatalf("appeal = %+v, %v", appealed, err)
+	}
+	if _, err := service.Appeal(ctx, user.ID, "again"); !errors.Is(err, ErrAlreadyAppealed) {
+		t.Fatalf("second appeal err = %v", err)
+	}
+	if admit := service.Admit(ctx, user.ID); admit.Allowed {
+		t.Fatalf("appeal alone lifted suspension: %+v", admit)
+	}
+
+	queue, err := service.Queue(ctx, "", 10)
+	if err != nil || len(queue) != 1 || queue[0].Username != "ada" || queue[0].Status != CaseAppealed {
+		t.Fatalf("queue = %+v, %v", queue, err)
+	}
+
+	if _, err := service.Resolve(ctx, appealed.ID, admin.ID, "ignore", ""); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("invalid action err = %v", err)
+	}
+	cleared, err := service.Resolve(ctx, appealed.ID, admin.ID, "clear", "benchmark confirmed")
+	if err != nil || cleared.Status != CaseCleared || cleared.ClosedAt == nil {
+		t.Fatalf("resolve = %+v, %v", cleared, err)
+	}
+	if admit := service.Admit(ctx, user.ID); !admit.Allowed || admit.CaseID != 0 {
+		t.Fatalf("admit after clear = %+v", admit)
+	}
+	if _, signals, err := service.CaseDetail(ctx, cleared.ID); err != nil || len(signals) != 2 {
+		t.Fatalf("case signals = %d, %v", len(signals), err)
+	}
+
+	// Cleared signals no longer count toward a new case
+	service.now = func() time.Time { return time.Now().Add(time.Second) }
+	if decision := service.Screen(ctx, user.ID, "exec-3", "smtplib.SMTP('mx.example.com', 25)"); !decision.Allowed || decision.CaseID != 0 {
+		t.Fatalf("screen after clear = %+v", decision)
+	}
+}
+
+func TestSMTPThrottlesFreeUserButOnlyQueuesPaidUser(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	free := createUser(t, db, "free", "free@example.com", "free")
+	paid := createUser(t, db, "paid", "paid@example.com", "pro")
+	cfg := DefaultConfig()
+	cfg.ThrottledRunsPerHour = 2
+	service := NewService(db, cfg)
+	spam := `import smtplib
+s = smtplib.SMTP("smtp.example.com", 587)`
+
+	for _, user := range []*models.User{free, paid} {
+		for i := 0; i < 2; i++ {
+			if decision := service.Screen(ctx, user.ID, "", spam); !decision.Allowed {
+				t.Fatalf("smtp screen refused %s: %+v", user.Username, decision)
+			}
+		}
+	}
+
+	admit := service.Admit(ctx, free.ID)
+	if !admit.Allowed || admit.MaxTimeout != cfg.ThrottledTimeout {
+		t.Fatalf("throttled admit = %+v", admit)
+	}
+	for i := 0; i < 2; i++ {
+		exec := &models.Execution{ExecutionID: "run-" + string(rune('a'+i)), UserID: free.ID, Command: "python", Language: "python"}
+		if err := db.Create(exec).Error; err != nil {
+			t.Fatalf("create execution: %v", err)
+		}
+	}
+	if admit := service.Admit(ctx, free.ID); admit.Allowed || admit.Code != CodeThrottled || admit.RetryAfter == 0 {
+		t.Fatalf("admit over hourly limit = %+v", admit)
+	}
+
+	if admit := service.Admit(ctx, paid.ID); !admit.Allowed || admit.MaxTimeout != 0 {
+		t.Fatalf("paid admit = %+v", admit)
+	}
+	open, err := service.OpenCase(ctx, paid.ID)
+	if err != nil || open == nil || open.Status != CaseReview || open.Enforcement != EnforceNone {
+		t.Fatalf("paid case = %+v, %v", open, err)
+	}
+	if _, err := service.Appeal(ctx, paid.ID, "why"); !errors.Is(err, ErrNoCase) {
+		t.Fatalf("paid appeal err = %v", err)
+	}
+}
+
+func TestObserveFlagsMinerOutputAndCPUBudget(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	user := createUser(t, db, "ada", "ada@example.com", "free")
+	cfg := DefaultConfig()
+	cfg.CPUMinutesPerHour = 1
+	service := NewService(db, cfg)
+
+	exec := &models.Execution{ExecutionID: "burn", UserID: user.ID, Command: "node", Language: "javascript", CPUTime: 90000}
+	if err := db.Create(exec).Error; err != nil {
+		t.Fatalf("create execution: %v", err)
+	}
+	service.Observe(ctx, user.ID, Run{ExecutionID: "burn", DurationMs: 30000, CPUTimeMs: 29500})
+	service.Observe(ctx, user.ID, Run{ExecutionID: "burn", DurationMs: 30000, CPUTimeMs: 29500})
+
+	var kinds []string
+	db.Model(&Signal{}).Where("user_id = ?", user.ID).Order("id").Pluck("kind", &kinds)
+	if len(kinds) != 3 || kinds[0] != SignalSustainedCPU || kinds[1] != SignalCPUBudget || kinds[2] != SignalSustainedCPU {
+		t.Fatalf("signal kinds = %v", kinds)
+	}
+	open, _ := service.OpenCase(ctx, user.ID)
+	if open == nil || open.Enforcement != EnforceThrottle {
+		t.Fatalf("case after cpu signals = %+v", open)
+	}
+
+	service.Observe(ctx, user.ID, Run{ExecutionID: "mine", Output: "[2026-01-01] net new job from pool.example:3333 diff 10000"})
+	if open, _ := service.OpenCase(ctx, user.ID); open == nil || open.Enforcement != EnforceSuspend {
+		t.Fatalf("case after miner output = %+v", open)
+	}
+}
+
+func TestDisposableEmailIsBlocked(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.DisposableDomains = []string{"Burner.Example"}
+	service := NewService(db, cfg)
+
+	for _, email := range []string{"x@mailinator.com", "x@eu.yopmail.com", "x@burner.example"} {
+		user := createUser(t, db, email, email, "free")
+		if admit := service.Admit(ctx, user.ID); admit.Allowed || admit.Code != CodeDisposableEmail {
+			t.Fatalf("admit %s = %+v", email, admit)
+		}
+	}
+	user := createUser(t, db, "ok", "ok@gmail.com", "free")
+	if admit := service.Admit(ctx, user.ID); !admit.Allowed {
+		t.Fatalf("admit regular email = %+v", admit)
+	}
+}
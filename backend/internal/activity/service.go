@@ -0,0 +1,175 @@
+// Package activity is the per-project activity feed: file edits, builds,
+// deployments, comments and collaborator joins, with who did them and when.
+// Subsystems record events as they happen; the feed lists them newest first
+// and a publisher pushes each new event to the project's collaboration room.
+package activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"apex-build/internal/pagination"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Event kinds.
+const (
+	KindFileEdit           = "file_edit"
+	KindBuild              = "build"
+	KindDeployment         = "deployment"
+	KindComment            = "comment"
+	KindCollaboratorJoined = "collaborator_joined"
+)
+
+// CoalesceWindow is how long repeated edits of the same file, or repeated
+// joins, by one actor fold into a single event instead of flooding the feed.
+const CoalesceWindow = 10 * time.Minute
+
+// ErrInvalid is returned for events without a project or kind.
+var ErrInvalid = errors.New("invalid activity event")
+
+// ListSpec is the feed's pagination: newest first, 50 per page.
+var ListSpec = pagination.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "occurred_at", Column: "occurred_at", Desc: true},
+	},
+}
+
+// Event is one entry in a project's activity feed.
+type Event struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ProjectID  uint      `gorm:"not null;index:idx_project_activities_feed,priority:1" json:"project_id"`
+	OccurredAt time.Time `gorm:"not null;index:idx_project_activities_feed,priority:2" json:"occurred_at"`
+	ActorID    uint      `gorm:"index" json:"actor_id"`
+	ActorName  string    `gorm:"size:100" json:"actor_name"`
+	Kind       string    `gorm:"not null;size:32" json:"kind"`
+	Action     string    `gorm:"size:32" json:"action"` // e.g. edited, completed, failed, live
+	Target     string    `gorm:"size:500" json:"target,omitempty"`
+	Summary    string    `gorm:"size:500" json:"summary"`
+	// Ref identifies what the event is about (a build, deployment or
+	// comment). An event whose ref was already recorded is dropped, so
+	// callers may report the same outcome more than once.
+	Ref      string         `gorm:"size:100;index" json:"ref,omitempty"`
+	Count    int            `gorm:"not null;default:1" json:"count"`
+	Metadata map[string]any `gorm:"serializer:json" json:"metadata,omitempty"`
+}
+
+func (Event) TableName() string { return "project_activities" }
+
+// Publisher receives every stored or coalesced event, for example to push
+// it over WebSocket. Implementations must not block.
+type Publisher func(Event)
+
+// Service records and lists project activity.
+type Service struct {
+	db      *gorm.DB
+	publish Publisher
+}
+
+// NewService creates a new activity Service backed by the given database.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// AutoMigrate creates the activity table.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Event{})
+}
+
+// SetPublisher sends recorded events to p.
+func (s *Service) SetPublisher(p Publisher) { s.publish = p }
+
+// Record stores an event. A file edit or join repeated by the same actor
+// within CoalesceWindow bumps the earlier event's count and time instead.
+func (s *Service) Record(ctx context.Context, event Event) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("activity: database unavailable")
+	}
+	if event.ProjectID == 0 || event.Kind == "" {
+		return ErrInvalid
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+	if event.Count <= 0 {
+		event.Count = 1
+	}
+	if event.ActorName == "" && event.ActorID != 0 {
+		var user models.User
+		if err := s.db.WithContext(ctx).Select("id", "username").First(&user, event.ActorID).Error; err == nil {
+			event.ActorName = user.Username
+		}
+	}
+
+	if event.Ref != "" {
+		var seen int64
+		if err := s.db.WithContext(ctx).Model(&Event{}).
+			Where("project_id = ? AND kind = ? AND ref = ?", event.ProjectID, event.Kind, event.Ref).
+			Count(&seen).Error; err != nil {
+			return fmt.Errorf("activity: dedupe failed: %w", err)
+		}
+		if seen > 0 {
+			return nil
+		}
+	}
+
+	if event.Kind == KindFileEdit || event.Kind == KindCollaboratorJoined {
+		var earlier Event
+		err := s.db.WithContext(ctx).
+			Where("project_id = ? AND kind = ? AND actor_id = ? AND target = ? AND occurred_at >= ?",
+				event.ProjectID, event.Kind, event.ActorID, event.Target, event.OccurredAt.Add(-CoalesceWindow)).
+			Order("occurred_at DESC").
+			First(&earlier).Error
+		if err == nil {
+			earlier.Count += event.Count
+			earlier.OccurredAt = event.OccurredAt
+			earlier.Summary = event.Summary
+			if err := s.db.WithContext(ctx).Model(&Event{}).Where("id = ?", earlier.ID).
+				Updates(map[string]any{"count": earlier.Count, "occurred_at": earlier.OccurredAt, "summary": earlier.Summary}).Error; err != nil {
+				return fmt.Errorf("activity: coalesce failed: %w", err)
+			}
+			s.emit(earlier)
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("activity: coalesce lookup failed: %w", err)
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Create(&event).Error; err != nil {
+		return fmt.Errorf("activity: create failed: %w", err)
+	}
+	s.emit(event)
+	return nil
+}
+
+// List returns a page of a project's activity, newest first, optionally
+// limited to some kinds.
+func (s *Service) List(ctx context.Context, projectID uint, list *pagination.Request, kinds []string) ([]Event, pagination.Info, error) {
+	if s == nil || s.db == nil {
+		return nil, pagination.Info{}, fmt.Errorf("activity: database unavailable")
+	}
+	query := s.db.WithContext(ctx).Where("project_id = ?", projectID)
+	if len(kinds) > 0 {
+		query = query.Where("kind IN ?", kinds)
+	}
+	var events []Event
+	if err := list.Apply(query).Find(&events).Error; err != nil {
+		return nil, pagination.Info{}, fmt.Errorf("activity: list failed: %w", err)
+	}
+	events, info := pagination.Page(list, events, func(e Event) (any, any) { return e.OccurredAt, e.ID })
+	return events, info, nil
+}
+
+func (s *Service) emit(event Event) {
+	if s.publish != nil {
+		s.publish(event)
+	}
+}
@@ -0,0 +1,108 @@
+package activity
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"apex-build/internal/pagination"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatalf("migrate users: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate activity: %v", err)
+	}
+	if err := db.Create(&models.User{Username: "ada", Email: "ada@example.com", PasswordHash: "x"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return NewService(db)
+}
+
+func listRequest(t *testing.T, query string) *pagination.Request {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/activity?"+query, nil)
+	list, err := pagination.Parse(c, ListSpec)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return list
+}
+
+func TestRecordCoalescesEditsAndDropsRepeatedRefs(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	var published []Event
+	s.SetPublisher(func(e Event) { published = append(published, e) })
+
+	start := time.Now().UTC().Add(-time.Hour)
+	edit := Event{ProjectID: 1, ActorID: 1, Kind: KindFileEdit, Action: "edited", Target: "src/App.tsx", Summary: "edited src/App.tsx", OccurredAt: start}
+	for i := 0; i < 3; i++ {
+		edit.OccurredAt = start.Add(time.Duration(i) * time.Minute)
+		if err := s.Record(ctx, edit); err != nil {
+			t.Fatalf("record edit: %v", err)
+		}
+	}
+	edit.OccurredAt = start.Add(CoalesceWindow + 5*time.Minute)
+	if err := s.Record(ctx, edit); err != nil {
+		t.Fatalf("record later edit: %v", err)
+	}
+
+	build := Event{ProjectID: 1, ActorID: 1, Kind: KindBuild, Action: "completed", Ref: "build:b1:completed", OccurredAt: start.Add(30 * time.Minute)}
+	for i := 0; i < 2; i++ {
+		if err := s.Record(ctx, build); err != nil {
+			t.Fatalf("record build: %v", err)
+		}
+	}
+	if err := s.Record(ctx, Event{ProjectID: 2, Kind: KindComment, Ref: "comment:1"}); err != nil {
+		t.Fatalf("record other project: %v", err)
+	}
+	if err := s.Record(ctx, Event{Kind: KindComment}); err != ErrInvalid {
+		t.Fatalf("event without project err = %v", err)
+	}
+
+	if len(published) != 6 {
+		t.Fatalf("published %d events, want 6 (repeated ref dropped)", len(published))
+	}
+	events, info, err := s.List(ctx, 1, listRequest(t, ""), nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(events) != 3 || info.HasMore {
+		t.Fatalf("events = %+v, info = %+v", events, info)
+	}
+	if events[0].Kind != KindBuild || events[1].Kind != KindFileEdit || events[1].Count != 1 || events[2].Count != 3 {
+		t.Fatalf("feed order or counts wrong: %+v", events)
+	}
+	if events[2].ActorName != "ada" {
+		t.Fatalf("actor name = %q, want looked up from users", events[2].ActorName)
+	}
+
+	page, info, err := s.List(ctx, 1, listRequest(t, "limit=2"), nil)
+	if err != nil || len(page) != 2 || !info.HasMore {
+		t.Fatalf("first page = %d events, %+v, %v", len(page), info, err)
+	}
+	rest, info, err := s.List(ctx, 1, listRequest(t, "limit=2&cursor="+info.NextCursor), nil)
+	if err != nil || len(rest) != 1 || info.HasMore || rest[0].ID != events[2].ID {
+		t.Fatalf("second page = %+v, %+v, %v", rest, info, err)
+	}
+
+	builds, _, err := s.List(ctx, 1, listRequest(t, ""), []string{KindBuild})
+	if err != nil || len(builds) != 1 || builds[0].Ref != "build:b1:completed" {
+		t.Fatalf("builds = %+v, %v", builds, err)
+	}
+}
@@ -0,0 +1,304 @@
+package agentmarket
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the agent marketplace API.
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new agent marketplace Handler.
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Search lists published agents.
+// GET /agent-marketplace?q=&role=
+func (h *Handler) Search(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	listings, err := h.service.Search(c.Request.Context(), c.Query("q"), c.Query("role"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to search agents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"agents": listings}})
+}
+
+// ListMine lists the caller's published agents, including unapproved ones.
+// GET /agent-marketplace/mine
+func (h *Handler) ListMine(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	listings, err := h.service.ListByAuthor(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load your agents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"agents": listings}})
+}
+
+// ListInstalled lists the agents installed in the caller's workspace.
+// GET /agent-marketplace/installed
+func (h *Handler) ListInstalled(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	installed, err := h.service.ListInstalled(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load installed agents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"installed": installed}})
+}
+
+// Publish creates a listing or publishes a new version of the caller's
+// listing. The version is held for moderation.
+// POST /agent-marketplace
+func (h *Handler) Publish(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var req PublishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	listing, version, err := h.service.Publish(c.Request.Context(), userID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"agent": listing, "version": version}})
+}
+
+// PublishVersion publishes a new version of an existing listing.
+// POST /agent-marketplace/:slug/versions
+func (h *Handler) PublishVersion(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var req PublishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	req.Slug = c.Param("slug")
+	if _, err := h.service.loadListing(c.Request.Context(), req.Slug); err != nil {
+		respondError(c, err)
+		return
+	}
+	listing, version, err := h.service.Publish(c.Request.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, ErrSlugTaken) {
+			err = ErrForbidden
+		}
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"agent": listing, "version": version}})
+}
+
+// Get returns a listing and its visible versions.
+// GET /agent-marketplace/:slug
+func (h *Handler) Get(c *gin.Context) {
+	listing, versions, err := h.service.Get(c.Request.Context(), c.GetUint("user_id"), c.Param("slug"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"agent": listing, "versions": versions}})
+}
+
+// Compatibility checks a version against the current build pipeline.
+// GET /agent-marketplace/:slug/compatibility?version=
+func (h *Handler) Compatibility(c *gin.Context) {
+	version, issues, err := h.service.Compatibility(c.Request.Context(), c.Param("slug"), c.Query("version"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"version":    version.Version,
+		"compatible": len(issues) == 0,
+		"issues":     issues,
+	}})
+}
+
+// Install installs a listing, optionally pinned to a version.
+// POST /agent-marketplace/:slug/install
+func (h *Handler) Install(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Version string `json:"version"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+			return
+		}
+	}
+	install, err := h.service.Install(c.Request.Context(), userID, c.Param("slug"), req.Version)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"install": install}})
+}
+
+// Uninstall removes a listing from the caller's workspace.
+// DELETE /agent-marketplace/:slug/install
+func (h *Handler) Uninstall(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Uninstall(c.Request.Context(), userID, c.Param("slug")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Rate records the caller's rating of an installed listing.
+// POST /agent-marketplace/:slug/rating
+func (h *Handler) Rate(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Stars   int    `json:"stars" binding:"required"`
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	listing, err := h.service.Rate(c.Request.Context(), userID, c.Param("slug"), req.Stars, req.Comment)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"agent": listing}})
+}
+
+// ListPending lists versions waiting for moderation.
+// GET /admin/agent-marketplace/pending
+func (h *Handler) ListPending(c *gin.Context) {
+	versions, err := h.service.PendingVersions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load pending agents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"versions": versions}})
+}
+
+// Moderate approves or rejects a pending version.
+// POST /admin/agent-marketplace/versions/:id/moderate
+func (h *Handler) Moderate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid id"})
+		return
+	}
+	var req struct {
+		Decision string `json:"decision" binding:"required,oneof=approve reject"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "decision must be approve or reject"})
+		return
+	}
+	version, err := h.service.ModerateVersion(c.Request.Context(), c.GetUint("user_id"), uint(id), req.Decision == "approve", req.Note)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"version": version}})
+}
+
+// SetStatus disables or re-enables a listing.
+// POST /admin/agent-marketplace/:slug/status
+func (h *Handler) SetStatus(c *gin.Context) {
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	listing, err := h.service.SetListingStatus(c.Request.Context(), c.Param("slug"), req.Status)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"agent": listing}})
+}
+
+func requireUser(c *gin.Context) (uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func respondError(c *gin.Context, err error) {
+	var incompatible *IncompatibleError
+	switch {
+	case errors.As(err, &incompatible):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "error": err.Error(), "issues": incompatible.Issues})
+	case errors.Is(err, ErrListingNotFound), errors.Is(err, ErrVersionNotFound), errors.Is(err, ErrNotInstalled):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ErrSlugTaken), errors.Is(err, ErrVersionExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ErrInvalidRating):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	}
+}
+
+// RegisterRoutes registers the authenticated marketplace endpoints.
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	mg := rg.Group("/agent-marketplace")
+	{
+		mg.GET("", h.Search)
+		mg.POST("", h.Publish)
+		mg.GET("/mine", h.ListMine)
+		mg.GET("/installed", h.ListInstalled)
+		mg.GET("/:slug", h.Get)
+		mg.GET("/:slug/compatibility", h.Compatibility)
+		mg.POST("/:slug/versions", h.PublishVersion)
+		mg.POST("/:slug/install", h.Install)
+		mg.DELETE("/:slug/install", h.Uninstall)
+		mg.POST("/:slug/rating", h.Rate)
+	}
+}
+
+// RegisterAdminRoutes registers the moderation endpoints on the admin group.
+func (h *Handler) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	mg := admin.Group("/agent-marketplace")
+	{
+		mg.GET("/pending", h.ListPending)
+		mg.POST("/versions/:id/moderate", h.Moderate)
+		mg.POST("/:slug/status", h.SetStatus)
+	}
+}
@@ -0,0 +1,623 @@
+// Package agentmarket is the community marketplace for custom agent role
+// configurations. Authors publish versioned agents.AgentProfile configs;
+// every version is held for moderation before it can be installed. Users
+// install a listing into their workspace, optionally pinning a version, and
+// installed profiles are applied to their new builds. A compatibility check
+// against the current build pipeline runs on publish, install and build start.
+package agentmarket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/agents"
+
+	"gorm.io/gorm"
+)
+
+// Listing statuses.
+const (
+	ListingActive   = "active"
+	ListingDisabled = "disabled"
+)
+
+// Version moderation statuses.
+const (
+	VersionPending  = "pending"
+	VersionApproved = "approved"
+	VersionRejected = "rejected"
+)
+
+var (
+	// ErrListingNotFound is returned when the listing does not exist or is not
+	// visible to the caller.
+	ErrListingNotFound = errors.New("agent listing not found")
+	// ErrVersionNotFound is returned when the version does not exist or is not
+	// approved.
+	ErrVersionNotFound = errors.New("agent version not found")
+	// ErrSlugTaken is returned when publishing a new listing under a used slug.
+	ErrSlugTaken = errors.New("an agent with this slug already exists")
+	// ErrVersionExists is returned when republishing an existing version.
+	ErrVersionExists = errors.New("this version has already been published")
+	// ErrForbidden is returned when the caller does not own the listing.
+	ErrForbidden = errors.New("only the author can publish versions of this agent")
+	// ErrNotInstalled is returned when rating or removing an agent that is not
+	// installed.
+	ErrNotInstalled = errors.New("agent is not installed")
+	// ErrInvalidRating is returned for ratings outside 1-5 or on one's own agent.
+	ErrInvalidRating = errors.New("rating must be 1-5 stars and cannot be on your own agent")
+)
+
+// IncompatibleError lists why a config cannot run in the current pipeline.
+type IncompatibleError struct {
+	Issues []string
+}
+
+func (e *IncompatibleError) Error() string {
+	return "agent is not compatible with the current pipeline: " + strings.Join(e.Issues, "; ")
+}
+
+// Listing is a published agent configuration.
+type Listing struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Slug          string   `json:"slug" gorm:"uniqueIndex;not null;size:80"`
+	Name          string   `json:"name" gorm:"not null;size:120"`
+	Description   string   `json:"description" gorm:"type:text"`
+	Role          string   `json:"role" gorm:"size:32;index"`
+	Tags          []string `json:"tags,omitempty" gorm:"serializer:json"`
+	AuthorID      uint     `json:"author_id" gorm:"not null;index"`
+	LatestVersion string   `json:"latest_version,omitempty" gorm:"size:32"` // newest approved version
+	Status        string   `json:"status" gorm:"size:20;not null;default:'active';index"`
+	Installs      int      `json:"installs" gorm:"default:0"`
+	Rating        float64  `json:"rating" gorm:"default:0"`
+	RatingCount   int      `json:"rating_count" gorm:"default:0"`
+}
+
+// TableName keeps the table name explicit.
+func (Listing) TableName() string { return "agent_listings" }
+
+// Version is one immutable, moderated release of a listing's config.
+type Version struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ListingID      uint                `json:"listing_id" gorm:"not null;uniqueIndex:idx_agent_listing_version"`
+	Version        string              `json:"version" gorm:"not null;size:32;uniqueIndex:idx_agent_listing_version"`
+	Config         agents.AgentProfile `json:"config" gorm:"serializer:json;type:text"`
+	Changelog      string              `json:"changelog,omitempty" gorm:"type:text"`
+	Status         string              `json:"status" gorm:"size:20;not null;index"`
+	Flags          []string            `json:"flags,omitempty" gorm:"serializer:json"` // automated moderation hints
+	ModerationNote string              `json:"moderation_note,omitempty" gorm:"type:text"`
+	ReviewedBy     *uint               `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time          `json:"reviewed_at,omitempty"`
+}
+
+// TableName keeps the table name explicit.
+func (Version) TableName() string { return "agent_listing_versions" }
+
+// Install records a listing installed into a user's workspace.
+type Install struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID        uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_agent_install_user_listing"`
+	ListingID     uint   `json:"listing_id" gorm:"not null;uniqueIndex:idx_agent_install_user_listing"`
+	PinnedVersion string `json:"pinned_version,omitempty" gorm:"size:32"` // empty follows the latest approved version
+	Enabled       bool   `json:"enabled" gorm:"not null;default:true"`
+}
+
+// TableName keeps the table name explicit.
+func (Install) TableName() string { return "agent_installs" }
+
+// Rating is one user's rating of a listing.
+type Rating struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_agent_rating_user_listing"`
+	ListingID uint   `json:"listing_id" gorm:"not null;uniqueIndex:idx_agent_rating_user_listing"`
+	Stars     int    `json:"stars" gorm:"not null"`
+	Comment   string `json:"comment,omitempty" gorm:"type:text"`
+}
+
+// TableName keeps the table name explicit.
+func (Rating) TableName() string { return "agent_ratings" }
+
+// PublishRequest creates a listing or a new version of one.
+type PublishRequest struct {
+	Slug        string              `json:"slug"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Tags        []string            `json:"tags"`
+	Version     string              `json:"version"`
+	Changelog   string              `json:"changelog"`
+	Config      agents.AgentProfile `json:"config"`
+}
+
+// InstalledAgent is an install resolved to the version builds will use.
+type InstalledAgent struct {
+	Install
+	Listing           Listing  `json:"listing"`
+	ResolvedVersion   string   `json:"resolved_version,omitempty"`
+	Compatible        bool     `json:"compatible"`
+	CompatibilityNote []string `json:"compatibility_issues,omitempty"`
+}
+
+var (
+	slugPattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,78}[a-z0-9]$`)
+	versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)$`)
+)
+
+// moderationFlagPatterns mark configs a reviewer should read closely.
+var moderationFlagPatterns = []struct {
+	flag    string
+	pattern *regexp.Regexp
+}{
+	{"overrides_platform_rules", regexp.MustCompile(`(?i)ignore (all |the )?(previous|prior|above|absolute) (instructions|rules)`)},
+	{"references_credentials", regexp.MustCompile(`(?i)\b(api[_ -]?keys?|secrets?|passwords?|access tokens?|private keys?)\b`)},
+	{"external_urls", regexp.MustCompile(`(?i)https?://`)},
+}
+
+// Service runs the agent marketplace.
+type Service struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewService creates a new agent marketplace Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// AutoMigrate creates the marketplace tables.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Listing{}, &Version{}, &Install{}, &Rating{})
+}
+
+func compareVersions(a, b string) int {
+	pa, pb := versionPattern.FindStringSubmatch(a), versionPattern.FindStringSubmatch(b)
+	if pa == nil || pb == nil {
+		return strings.Compare(a, b)
+	}
+	for i := 1; i <= 3; i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func moderationFlags(config agents.AgentProfile) []string {
+	text := config.Instructions + "\n" + strings.Join(config.Constraints, "\n")
+	var flags []string
+	for _, candidate := range moderationFlagPatterns {
+		if candidate.pattern.MatchString(text) {
+			flags = append(flags, candidate.flag)
+		}
+	}
+	return flags
+}
+
+// Publish creates a listing with its first version, or adds a version to the
+// caller's existing listing. New versions wait for moderation.
+func (s *Service) Publish(ctx context.Context, authorID uint, req PublishRequest) (*Listing, *Version, error) {
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	req.Version = strings.TrimSpace(req.Version)
+	if !slugPattern.MatchString(req.Slug) {
+		return nil, nil, fmt.Errorf("slug must be 3-80 lowercase letters, digits or dashes")
+	}
+	if !versionPattern.MatchString(req.Version) {
+		return nil, nil, fmt.Errorf("version must look like 1.2.3")
+	}
+	req.Config.Role = agents.AgentRole(strings.ToLower(strings.TrimSpace(string(req.Config.Role))))
+	req.Config.PreferredProvider = strings.ToLower(strings.TrimSpace(req.Config.PreferredProvider))
+	req.Config.Source = ""
+	if issues := agents.CheckAgentProfileCompatibility(req.Config); len(issues) > 0 {
+		return nil, nil, &IncompatibleError{Issues: issues}
+	}
+
+	var listing Listing
+	var version Version
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("slug = ?", req.Slug).First(&listing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if strings.TrimSpace(req.Name) == "" {
+				return fmt.Errorf("name is required for a new agent")
+			}
+			listing = Listing{
+				Slug:        req.Slug,
+				Name:        strings.TrimSpace(req.Name),
+				Description: strings.TrimSpace(req.Description),
+				Role:        string(req.Config.Role),
+				Tags:        req.Tags,
+				AuthorID:    authorID,
+				Status:      ListingActive,
+			}
+			if err := tx.Create(&listing).Error; err != nil {
+				return fmt.Errorf("agentmarket: create listing failed: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("agentmarket: load listing failed: %w", err)
+		case listing.AuthorID != authorID:
+			return ErrSlugTaken
+		case listing.Role != string(req.Config.Role):
+			return fmt.Errorf("new versions must keep the %s role", listing.Role)
+		}
+
+		var existing int64
+		if err := tx.Model(&Version{}).Where("listing_id = ? AND version = ?", listing.ID, req.Version).Count(&existing).Error; err != nil {
+			return fmt.Errorf("agentmarket: check version failed: %w", err)
+		}
+		if existing > 0 {
+			return ErrVersionExists
+		}
+		version = Version{
+			ListingID: listing.ID,
+			Version:   req.Version,
+			Config:    req.Config,
+			Changelog: strings.TrimSpace(req.Changelog),
+			Status:    VersionPending,
+			Flags:     moderationFlags(req.Config),
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return fmt.Errorf("agentmarket: create version failed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &listing, &version, nil
+}
+
+// Search returns active listings with at least one approved version.
+func (s *Service) Search(ctx context.Context, query, role string, limit int) ([]Listing, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	q := s.db.WithContext(ctx).Where("status = ? AND latest_version <> ''", ListingActive)
+	if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+		q = q.Where("role = ?", role)
+	}
+	if query = strings.ToLower(strings.TrimSpace(query)); query != "" {
+		like := "%" + strings.NewReplacer("%", `\%`, "_", `\_`).Replace(query) + "%"
+		q = q.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR slug LIKE ?", like, like, like)
+	}
+	var listings []Listing
+	if err := q.Order("rating DESC").Order("installs DESC").Limit(limit).Find(&listings).Error; err != nil {
+		return nil, fmt.Errorf("agentmarket: search failed: %w", err)
+	}
+	return listings, nil
+}
+
+// ListByAuthor returns the author's listings in every state.
+func (s *Service) ListByAuthor(ctx context.Context, authorID uint) ([]Listing, error) {
+	var listings []Listing
+	if err := s.db.WithContext(ctx).Where("author_id = ?", authorID).Order("updated_at DESC").Find(&listings).Error; err != nil {
+		return nil, fmt.Errorf("agentmarket: list listings failed: %w", err)
+	}
+	return listings, nil
+}
+
+// Get returns a listing and the versions the caller may see: approved ones,
+// plus every version when the caller is the author.
+func (s *Service) Get(ctx context.Context, userID uint, slug string) (*Listing, []Version, error) {
+	listing, err := s.loadListing(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	isAuthor := listing.AuthorID == userID
+	if !isAuthor && (listing.Status != ListingActive || listing.LatestVersion == "") {
+		return nil, nil, ErrListingNotFound
+	}
+	q := s.db.WithContext(ctx).Where("listing_id = ?", listing.ID)
+	if !isAuthor {
+		q = q.Where("status = ?", VersionApproved)
+	}
+	var versions []Version
+	if err := q.Order("id DESC").Find(&versions).Error; err != nil {
+		return nil, nil, fmt.Errorf("agentmarket: load versions failed: %w", err)
+	}
+	return listing, versions, nil
+}
+
+// Compatibility checks a version (the latest approved one when version is
+// empty) against the current pipeline.
+func (s *Service) Compatibility(ctx context.Context, slug, version string) (*Version, []string, error) {
+	listing, err := s.loadListing(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, err := s.approvedVersion(ctx, listing, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resolved, agents.CheckAgentProfileCompatibility(resolved.Config), nil
+}
+
+// Install adds a listing to the user's workspace, or changes its pin.
+// pinnedVersion may be empty to follow the latest approved version.
+func (s *Service) Install(ctx context.Context, userID uint, slug, pinnedVersion string) (*Install, error) {
+	listing, err := s.loadListing(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if listing.Status != ListingActive {
+		return nil, ErrListingNotFound
+	}
+	pinnedVersion = strings.TrimSpace(pinnedVersion)
+	resolved, err := s.approvedVersion(ctx, listing, pinnedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if issues := agents.CheckAgentProfileCompatibility(resolved.Config); len(issues) > 0 {
+		return nil, &IncompatibleError{Issues: issues}
+	}
+
+	var install Install
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND listing_id = ?", userID, listing.ID).First(&install).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			install = Install{UserID: userID, ListingID: listing.ID, PinnedVersion: pinnedVersion, Enabled: true}
+			if err := tx.Create(&install).Error; err != nil {
+				return fmt.Errorf("agentmarket: install failed: %w", err)
+			}
+			return tx.Model(&Listing{}).Where("id = ?", listing.ID).UpdateColumn("installs", gorm.Expr("installs + 1")).Error
+		}
+		if err != nil {
+			return fmt.Errorf("agentmarket: load install failed: %w", err)
+		}
+		return tx.Model(&install).Updates(map[string]any{"pinned_version": pinnedVersion, "enabled": true}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	install.PinnedVersion = pinnedVersion
+	install.Enabled = true
+	return &install, nil
+}
+
+// Uninstall removes a listing from the user's workspace.
+func (s *Service) Uninstall(ctx context.Context, userID uint, slug string) error {
+	listing, err := s.loadListing(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND listing_id = ?", userID, listing.ID).Delete(&Install{})
+		if result.Error != nil {
+			return fmt.Errorf("agentmarket: uninstall failed: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotInstalled
+		}
+		return tx.Model(&Listing{}).Where("id = ? AND installs > 0", listing.ID).UpdateColumn("installs", gorm.Expr("installs - 1")).Error
+	})
+}
+
+// ListInstalled returns the user's installs resolved to the version their
+// builds will use.
+func (s *Service) ListInstalled(ctx context.Context, userID uint) ([]InstalledAgent, error) {
+	var installs []Install
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&installs).Error; err != nil {
+		return nil, fmt.Errorf("agentmarket: list installs failed: %w", err)
+	}
+	out := make([]InstalledAgent, 0, len(installs))
+	for _, install := range installs {
+		entry := InstalledAgent{Install: install}
+		if err := s.db.WithContext(ctx).First(&entry.Listing, install.ListingID).Error; err != nil {
+			continue
+		}
+		if entry.Listing.Status != ListingActive {
+			entry.CompatibilityNote = []string{"the agent was disabled by moderators"}
+			out = append(out, entry)
+			continue
+		}
+		version, err := s.approvedVersion(ctx, &entry.Listing, install.PinnedVersion)
+		if err != nil {
+			entry.CompatibilityNote = []string{err.Error()}
+			out = append(out, entry)
+			continue
+		}
+		entry.ResolvedVersion = version.Version
+		entry.CompatibilityNote = agents.CheckAgentProfileCompatibility(version.Config)
+		entry.Compatible = len(entry.CompatibilityNote) == 0
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// InstalledAgentProfiles returns the profiles of the user's enabled,
+// compatible installs. It satisfies agents.AgentProfileSource.
+func (s *Service) InstalledAgentProfiles(ctx context.Context, userID uint) ([]agents.AgentProfile, error) {
+	installed, err := s.ListInstalled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var profiles []agents.AgentProfile
+	for _, entry := range installed {
+		if !entry.Enabled || !entry.Compatible {
+			continue
+		}
+		version, err := s.approvedVersion(ctx, &entry.Listing, entry.ResolvedVersion)
+		if err != nil {
+			continue
+		}
+		profile := version.Config
+		if strings.TrimSpace(profile.Name) == "" {
+			profile.Name = entry.Listing.Name
+		}
+		profile.Source = fmt.Sprintf("marketplace:%s@%s", entry.Listing.Slug, version.Version)
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// Rate records or updates the user's rating of an installed listing.
+func (s *Service) Rate(ctx context.Context, userID uint, slug string, stars int, comment string) (*Listing, error) {
+	listing, err := s.loadListing(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if stars < 1 || stars > 5 || listing.AuthorID == userID {
+		return nil, ErrInvalidRating
+	}
+	var installed int64
+	if err := s.db.WithContext(ctx).Model(&Install{}).Where("user_id = ? AND listing_id = ?", userID, listing.ID).Count(&installed).Error; err != nil {
+		return nil, fmt.Errorf("agentmarket: check install failed: %w", err)
+	}
+	if installed == 0 {
+		return nil, ErrNotInstalled
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rating Rating
+		err := tx.Where("user_id = ? AND listing_id = ?", userID, listing.ID).First(&rating).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			rating = Rating{UserID: userID, ListingID: listing.ID, Stars: stars, Comment: strings.TrimSpace(comment)}
+			if err := tx.Create(&rating).Error; err != nil {
+				return fmt.Errorf("agentmarket: save rating failed: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("agentmarket: load rating failed: %w", err)
+		default:
+			if err := tx.Model(&rating).Updates(map[string]any{"stars": stars, "comment": strings.TrimSpace(comment)}).Error; err != nil {
+				return fmt.Errorf("agentmarket: save rating failed: %w", err)
+			}
+		}
+
+		var stats struct {
+			Average float64
+			Count   int
+		}
+		if err := tx.Model(&Rating{}).Select("COALESCE(AVG(stars), 0) AS average, COUNT(*) AS count").
+			Where("listing_id = ?", listing.ID).Scan(&stats).Error; err != nil {
+			return fmt.Errorf("agentmarket: aggregate ratings failed: %w", err)
+		}
+		return tx.Model(&Listing{}).Where("id = ?", listing.ID).
+			Updates(map[string]any{"rating": stats.Average, "rating_count": stats.Count}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.loadListing(ctx, slug)
+}
+
+// PendingVersions returns versions waiting for moderation, oldest first.
+func (s *Service) PendingVersions(ctx context.Context) ([]Version, error) {
+	var versions []Version
+	if err := s.db.WithContext(ctx).Where("status = ?", VersionPending).Order("id").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("agentmarket: list pending versions failed: %w", err)
+	}
+	return versions, nil
+}
+
+// ModerateVersion approves or rejects a pending version. Approval makes it
+// installable and, if newest, the listing's latest version.
+func (s *Service) ModerateVersion(ctx context.Context, reviewerID, versionID uint, approve bool, note string) (*Version, error) {
+	var version Version
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&version, versionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVersionNotFound
+			}
+			return fmt.Errorf("agentmarket: load version failed: %w", err)
+		}
+		now := s.now()
+		version.Status = VersionRejected
+		if approve {
+			version.Status = VersionApproved
+		}
+		version.ModerationNote = strings.TrimSpace(note)
+		version.ReviewedBy = &reviewerID
+		version.ReviewedAt = &now
+		if err := tx.Save(&version).Error; err != nil {
+			return fmt.Errorf("agentmarket: save version failed: %w", err)
+		}
+		return s.refreshLatestVersion(tx, version.ListingID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// SetListingStatus disables or re-enables a whole listing. Disabled listings
+// disappear from search and stop applying to builds.
+func (s *Service) SetListingStatus(ctx context.Context, slug, status string) (*Listing, error) {
+	if status != ListingActive && status != ListingDisabled {
+		return nil, fmt.Errorf("status must be %q or %q", ListingActive, ListingDisabled)
+	}
+	listing, err := s.loadListing(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(listing).Update("status", status).Error; err != nil {
+		return nil, fmt.Errorf("agentmarket: update listing failed: %w", err)
+	}
+	listing.Status = status
+	return listing, nil
+}
+
+func (s *Service) refreshLatestVersion(tx *gorm.DB, listingID uint) error {
+	var approved []Version
+	if err := tx.Where("listing_id = ? AND status = ?", listingID, VersionApproved).Find(&approved).Error; err != nil {
+		return fmt.Errorf("agentmarket: load approved versions failed: %w", err)
+	}
+	latest := ""
+	for _, version := range approved {
+		if latest == "" || compareVersions(version.Version, latest) > 0 {
+			latest = version.Version
+		}
+	}
+	return tx.Model(&Listing{}).Where("id = ?", listingID).Update("latest_version", latest).Error
+}
+
+func (s *Service) loadListing(ctx context.Context, slug string) (*Listing, error) {
+	var listing Listing
+	if err := s.db.WithContext(ctx).Where("slug = ?", strings.ToLower(strings.TrimSpace(slug))).First(&listing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrListingNotFound
+		}
+		return nil, fmt.Errorf("agentmarket: load listing failed: %w", err)
+	}
+	return &listing, nil
+}
+
+// approvedVersion resolves version, or the latest approved one when empty.
+func (s *Service) approvedVersion(ctx context.Context, listing *Listing, version string) (*Version, error) {
+	if version == "" {
+		version = listing.LatestVersion
+	}
+	if version == "" {
+		return nil, ErrVersionNotFound
+	}
+	var resolved Version
+	err := s.db.WithContext(ctx).
+		Where("listing_id = ? AND version = ? AND status = ?", listing.ID, version, VersionApproved).
+		First(&resolved).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("agentmarket: load version failed: %w", err)
+	}
+	return &resolved, nil
+}
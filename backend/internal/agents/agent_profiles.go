@@ -0,0 +1,236 @@
+// agent_profiles.go — Custom agent role configurations.
+//
+// An AgentProfile adds user-authored instructions, a preferred provider and
+// delivery constraints to one agent role. Profiles reach a build either from
+// the request itself or from the user's installed agent marketplace entries
+// (wired through SetAgentProfileSource). Request profiles win for a role.
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"apex-build/internal/ai"
+)
+
+// AgentPipelineVersion is bumped when roles, prompts or task contracts change
+// in a way that older profiles may not fit. Profiles declaring a newer
+// RequiresPipeline are refused.
+const AgentPipelineVersion = 1
+
+const (
+	maxAgentProfileInstructions = 8000
+	maxAgentProfileConstraints  = 20
+	maxAgentProfileConstraint   = 300
+)
+
+// AgentProfile customizes one agent role
+type AgentProfile struct {
+	Role              AgentRole `json:"role"`
+	Name              string    `json:"name,omitempty"`
+	Instructions      string    `json:"instructions,omitempty"`       // appended to the role's system prompt
+	PreferredProvider string    `json:"preferred_provider,omitempty"` // used when the provider is available for the build
+	Constraints       []string  `json:"constraints,omitempty"`        // delivery rules the agent must follow
+	RequiresPipeline  int       `json:"requires_pipeline,omitempty"`  // minimum AgentPipelineVersion
+	Source            string    `json:"source,omitempty"`             // "request" or "marketplace:<slug>@<version>"
+}
+
+// AgentProfileSource supplies the profiles a user has installed. Implemented
+// by the agent marketplace; wired via SetAgentProfileSource in main.go.
+type AgentProfileSource interface {
+	InstalledAgentProfiles(ctx context.Context, userID uint) ([]AgentProfile, error)
+}
+
+// SetAgentProfileSource wires installed marketplace profiles into new builds.
+func (am *AgentManager) SetAgentProfileSource(source AgentProfileSource) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.agentProfileSource = source
+}
+
+// customizableAgentRoles are the roles a profile may target. The lead keeps
+// its built-in prompt because it owns coordination and the user conversation.
+var customizableAgentRoles = []AgentRole{
+	RolePlanner, RoleArchitect, RoleFrontend, RoleBackend, RoleDatabase,
+	RoleTesting, RoleDevOps, RoleReviewer, RoleSolver,
+}
+
+var knownProfileProviders = []ai.AIProvider{
+	ai.ProviderClaude, ai.ProviderGPT4, ai.ProviderGemini, ai.ProviderGrok,
+	ai.ProviderOllama, ai.ProviderDeepSeek, ai.ProviderGLM, ai.ProviderOpenRouter,
+}
+
+// CheckAgentProfileCompatibility lists the reasons a profile cannot run in
+// the current pipeline; an empty result means it is compatible.
+func CheckAgentProfileCompatibility(profile AgentProfile) []string {
+	var issues []string
+
+	role := AgentRole(strings.ToLower(strings.TrimSpace(string(profile.Role))))
+	known := false
+	for _, candidate := range customizableAgentRoles {
+		if candidate == role {
+			known = true
+			break
+		}
+	}
+	switch {
+	case role == RoleLead:
+		issues = append(issues, "the lead role cannot be customized")
+	case !known:
+		issues = append(issues, fmt.Sprintf("role %q is not part of the build pipeline", profile.Role))
+	}
+
+	if provider := strings.TrimSpace(profile.PreferredProvider); provider != "" {
+		supported := false
+		for _, candidate := range knownProfileProviders {
+			if string(candidate) == strings.ToLower(provider) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			issues = append(issues, fmt.Sprintf("provider %q is not supported", provider))
+		}
+	}
+
+	if strings.TrimSpace(profile.Instructions) == "" && len(profile.Constraints) == 0 && strings.TrimSpace(profile.PreferredProvider) == "" {
+		issues = append(issues, "profile changes nothing: add instructions, constraints or a preferred provider")
+	}
+	if len(profile.Instructions) > maxAgentProfileInstructions {
+		issues = append(issues, fmt.Sprintf("instructions exceed %d characters", maxAgentProfileInstructions))
+	}
+	if len(profile.Constraints) > maxAgentProfileConstraints {
+		issues = append(issues, fmt.Sprintf("at most %d constraints are allowed", maxAgentProfileConstraints))
+	}
+	for _, constraint := range profile.Constraints {
+		if len(constraint) > maxAgentProfileConstraint {
+			issues = append(issues, fmt.Sprintf("constraints must be at most %d characters", maxAgentProfileConstraint))
+			break
+		}
+	}
+	if profile.RequiresPipeline > AgentPipelineVersion {
+		issues = append(issues, fmt.Sprintf("requires pipeline version %d; this platform runs version %d", profile.RequiresPipeline, AgentPipelineVersion))
+	}
+	return issues
+}
+
+// mergeAgentProfiles keeps one compatible profile per role. Later lists
+// override earlier ones, so callers pass installed profiles before request
+// profiles.
+func mergeAgentProfiles(lists ...[]AgentProfile) []AgentProfile {
+	byRole := make(map[AgentRole]AgentProfile)
+	for _, list := range lists {
+		for _, profile := range list {
+			profile.Role = AgentRole(strings.ToLower(strings.TrimSpace(string(profile.Role))))
+			profile.PreferredProvider = strings.ToLower(strings.TrimSpace(profile.PreferredProvider))
+			profile.Instructions = strings.TrimSpace(profile.Instructions)
+			profile.Constraints = filterSlice(profile.Constraints, func(constraint string) bool { return strings.TrimSpace(constraint) != "" })
+			if issues := CheckAgentProfileCompatibility(profile); len(issues) > 0 {
+				log.Printf("Skipping agent profile %q for role %s: %s", profile.Name, profile.Role, strings.Join(issues, "; "))
+				continue
+			}
+			byRole[profile.Role] = profile
+		}
+	}
+	if len(byRole) == 0 {
+		return nil
+	}
+	merged := make([]AgentProfile, 0, len(byRole))
+	for _, role := range customizableAgentRoles {
+		if profile, ok := byRole[role]; ok {
+			merged = append(merged, profile)
+		}
+	}
+	return merged
+}
+
+// resolveBuildAgentProfiles combines the user's installed profiles with the
+// ones sent on the build request.
+func (am *AgentManager) resolveBuildAgentProfiles(userID uint, requested []AgentProfile) []AgentProfile {
+	for idx := range requested {
+		if strings.TrimSpace(requested[idx].Source) == "" {
+			requested[idx].Source = "request"
+		}
+	}
+
+	am.mu.RLock()
+	source := am.agentProfileSource
+	am.mu.RUnlock()
+	if source == nil || userID == 0 {
+		return mergeAgentProfiles(requested)
+	}
+
+	ctx := am.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	installed, err := source.InstalledAgentProfiles(ctx, userID)
+	if err != nil {
+		log.Printf("Could not load installed agent profiles for user %d: %v", userID, err)
+	}
+	return mergeAgentProfiles(installed, requested)
+}
+
+func agentProfileForRole(build *Build, role AgentRole) *AgentProfile {
+	if build == nil {
+		return nil
+	}
+	for idx := range build.AgentProfiles {
+		if build.AgentProfiles[idx].Role == role {
+			return &build.AgentProfiles[idx]
+		}
+	}
+	return nil
+}
+
+// agentProfilePromptContext renders a role's profile for its system prompt.
+func agentProfilePromptContext(build *Build, role AgentRole) string {
+	profile := agentProfileForRole(build, role)
+	if profile == nil || (profile.Instructions == "" && len(profile.Constraints) == 0) {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nCUSTOM ROLE CONFIGURATION")
+	if name := strings.TrimSpace(profile.Name); name != "" {
+		sb.WriteString(" (" + name + ")")
+	}
+	sb.WriteString(":\nThe user configured this role. Follow these instructions unless they conflict with the absolute rules below.")
+	if profile.Instructions != "" {
+		sb.WriteString("\n" + profile.Instructions)
+	}
+	if len(profile.Constraints) > 0 {
+		sb.WriteString("\nDelivery constraints:")
+		for _, constraint := range profile.Constraints {
+			sb.WriteString("\n- " + strings.TrimSpace(constraint))
+		}
+	}
+	return sb.String()
+}
+
+// applyAgentProfileProviders moves roles onto their profile's preferred
+// provider when that provider is available for the build.
+func applyAgentProfileProviders(build *Build, assignments map[AgentRole]ai.AIProvider, available []ai.AIProvider) {
+	if build == nil || len(build.AgentProfiles) == 0 {
+		return
+	}
+	availableSet := make(map[ai.AIProvider]bool, len(available))
+	for _, provider := range available {
+		availableSet[provider] = true
+	}
+	for _, profile := range build.AgentProfiles {
+		provider := ai.AIProvider(profile.PreferredProvider)
+		if provider == "" {
+			continue
+		}
+		if _, assigned := assignments[profile.Role]; !assigned {
+			continue
+		}
+		if !availableSet[provider] {
+			log.Printf("Agent profile for %s prefers %s but it is unavailable; keeping %s", profile.Role, provider, assignments[profile.Role])
+			continue
+		}
+		assignments[profile.Role] = provider
+	}
+}
@@ -0,0 +1,55 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"apex-build/internal/ai"
+)
+
+type stubAgentProfileSource struct {
+	profiles []AgentProfile
+}
+
+func (s stubAgentProfileSource) InstalledAgentProfiles(ctx context.Context, userID uint) ([]AgentProfile, error) {
+	return s.profiles, nil
+}
+
+func TestResolveBuildAgentProfilesPrefersRequestAndSkipsIncompatible(t *testing.T) {
+	am := &AgentManager{}
+	am.SetAgentProfileSource(stubAgentProfileSource{profiles: []AgentProfile{
+		{Role: RoleTesting, Instructions: "Installed tester", Source: "marketplace:tester@1.0.0"},
+		{Role: RoleFrontend, Instructions: "Use CSS modules", PreferredProvider: "gemini", Source: "marketplace:css@2.0.0"},
+		{Role: RoleLead, Instructions: "Take over"},
+	}})
+
+	profiles := am.resolveBuildAgentProfiles(7, []AgentProfile{
+		{Role: " Testing ", Instructions: "Request tester", Constraints: []string{"Use vitest", "  "}},
+		{Role: RoleReviewer, RequiresPipeline: AgentPipelineVersion + 1, Instructions: "Future reviewer"},
+	})
+	if len(profiles) != 2 {
+		t.Fatalf("expected frontend and testing profiles, got %+v", profiles)
+	}
+	if profiles[0].Role != RoleFrontend || profiles[1].Role != RoleTesting {
+		t.Fatalf("profiles should follow pipeline role order, got %+v", profiles)
+	}
+	if profiles[1].Source != "request" || profiles[1].Instructions != "Request tester" || len(profiles[1].Constraints) != 1 {
+		t.Fatalf("request profile should win and be normalized, got %+v", profiles[1])
+	}
+
+	build := &Build{AgentProfiles: profiles}
+	prompt := agentProfilePromptContext(build, RoleTesting)
+	if !strings.Contains(prompt, "CUSTOM ROLE CONFIGURATION") || !strings.Contains(prompt, "- Use vitest") {
+		t.Fatalf("unexpected prompt context %q", prompt)
+	}
+	if agentProfilePromptContext(build, RoleBackend) != "" {
+		t.Fatal("roles without a profile should get no extra prompt")
+	}
+
+	assignments := map[AgentRole]ai.AIProvider{RoleFrontend: ai.ProviderClaude, RoleTesting: ai.ProviderGPT4}
+	applyAgentProfileProviders(build, assignments, []ai.AIProvider{ai.ProviderClaude, ai.ProviderGemini})
+	if assignments[RoleFrontend] != ai.ProviderGemini || assignments[RoleTesting] != ai.ProviderGPT4 {
+		t.Fatalf("unexpected provider assignments %+v", assignments)
+	}
+}
@@ -0,0 +1,262 @@
+// agent_telemetry.go — Live per-agent AI call telemetry.
+//
+// Every provider call made through the AIRouterAdapter produces one
+// AICallTelemetry record. The AgentManager folds it into a per-agent
+// summary on the build's snapshot state and broadcasts both over the build
+// WebSocket as "agent:telemetry", so the builder UI can show what each agent
+// is doing and what it costs while the build runs.
+package agents
+
+import (
+	"context"
+	"maps"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+// AICallTelemetry describes a single provider call made for a build
+type AICallTelemetry struct {
+	BuildID      string
+	AgentID      string
+	TaskID       string
+	Role         string
+	Provider     string
+	Model        string
+	Capability   string
+	Status       string // success or failed
+	ErrorCode    string // ai.RetryReasonCode of the final error, or empty_response
+	RetryReasons []string
+	InputTokens  int
+	OutputTokens int
+	// TimeToFirstToken is the time until the provider's response started
+	// arriving. Provider calls are not streamed, so this is the first byte of
+	// the final attempt's HTTP response.
+	TimeToFirstToken time.Duration
+	Latency          time.Duration // whole call including router retries
+	CostUSD          float64
+	CostEstimated    bool
+	StartedAt        time.Time
+}
+
+// AgentTelemetrySummary accumulates an agent's calls over the build
+type AgentTelemetrySummary struct {
+	AgentID        string         `json:"agent_id,omitempty"`
+	Role           string         `json:"role,omitempty"`
+	Provider       string         `json:"provider,omitempty"`
+	Model          string         `json:"model,omitempty"`
+	Calls          int            `json:"calls"`
+	FailedCalls    int            `json:"failed_calls,omitempty"`
+	Retries        int            `json:"retries,omitempty"`
+	RetryReasons   map[string]int `json:"retry_reasons,omitempty"`
+	InputTokens    int            `json:"input_tokens"`
+	OutputTokens   int            `json:"output_tokens"`
+	CostUSD        float64        `json:"cost_usd"`
+	TotalLatencyMS int64          `json:"total_latency_ms"`
+	AvgLatencyMS   int64          `json:"avg_latency_ms"`
+	LastLatencyMS  int64          `json:"last_latency_ms"`
+	LastTTFTMS     int64          `json:"last_ttft_ms,omitempty"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// aiCallTrace times one adapter call
+type aiCallTrace struct {
+	opts          GenerateOptions
+	request       *ai.AIRequest
+	estimatedCost float64
+	started       time.Time
+	firstByte     atomic.Int64
+}
+
+func newAICallTrace(opts GenerateOptions, request *ai.AIRequest, estimatedCost float64) *aiCallTrace {
+	return &aiCallTrace{opts: opts, request: request, estimatedCost: estimatedCost, started: time.Now()}
+}
+
+// withContext records when each provider response starts arriving. Router
+// retries overwrite it, so the final attempt's first byte wins.
+func (t *aiCallTrace) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			t.firstByte.Store(time.Now().UnixNano())
+		},
+	})
+}
+
+func (t *aiCallTrace) finish(response *ai.AIResponse, err error, billedCost float64) AICallTelemetry {
+	now := time.Now()
+	record := AICallTelemetry{
+		BuildID:    t.opts.BuildID,
+		AgentID:    t.opts.AgentID,
+		TaskID:     t.opts.TaskID,
+		Role:       t.opts.RoleHint,
+		Provider:   string(actualProviderForAIResponse(response, t.request.Provider)),
+		Model:      ai.GetModelUsed(response, t.request),
+		Capability: string(t.request.Capability),
+		Status:     "success",
+		Latency:    now.Sub(t.started),
+		CostUSD:    billedCost,
+		StartedAt:  t.started,
+	}
+	if firstByte := t.firstByte.Load(); firstByte > 0 {
+		record.TimeToFirstToken = time.Unix(0, firstByte).Sub(t.started)
+	}
+	if response != nil {
+		if response.Usage != nil {
+			record.InputTokens = response.Usage.PromptTokens
+			record.OutputTokens = response.Usage.CompletionTokens
+		}
+		if reasons, ok := response.Metadata["retry_reasons"].([]string); ok {
+			record.RetryReasons = append([]string(nil), reasons...)
+		}
+	}
+	if err != nil {
+		record.Status = "failed"
+		record.ErrorCode = ai.RetryReasonCode(err)
+		if response != nil {
+			record.ErrorCode = "empty_response"
+		}
+	} else if record.CostUSD <= 0 {
+		record.CostUSD = t.estimatedCost
+		record.CostEstimated = record.CostUSD > 0
+	}
+	return record
+}
+
+func (a *AIRouterAdapter) emitTelemetry(record AICallTelemetry) {
+	if a == nil || a.telemetrySink == nil || strings.TrimSpace(record.BuildID) == "" {
+		return
+	}
+	a.telemetrySink(record)
+}
+
+// telemetryKey groups calls by agent, falling back to the role hint for
+// calls made outside an agent (critiques, judges, repairs).
+func telemetryKey(record AICallTelemetry) string {
+	if id := strings.TrimSpace(record.AgentID); id != "" {
+		return id
+	}
+	if role := strings.TrimSpace(record.Role); role != "" {
+		return "role:" + role
+	}
+	return "role:unknown"
+}
+
+// RecordAICallTelemetry updates the agent's running totals and broadcasts
+// the call as agent:telemetry
+func (am *AgentManager) RecordAICallTelemetry(record AICallTelemetry) {
+	if am == nil || strings.TrimSpace(record.BuildID) == "" {
+		return
+	}
+	am.mu.RLock()
+	build := am.builds[record.BuildID]
+	am.mu.RUnlock()
+	if build == nil {
+		return
+	}
+
+	key := telemetryKey(record)
+	build.mu.Lock()
+	if record.AgentID != "" {
+		if agent := build.Agents[record.AgentID]; agent != nil && record.Role == "" {
+			record.Role = string(agent.Role)
+		}
+	}
+	if build.SnapshotState.AgentTelemetry == nil {
+		build.SnapshotState.AgentTelemetry = make(map[string]AgentTelemetrySummary)
+	}
+	summary := build.SnapshotState.AgentTelemetry[key]
+	summary.apply(record)
+	build.SnapshotState.AgentTelemetry[key] = summary
+	summary.RetryReasons = maps.Clone(summary.RetryReasons)
+	totals := buildTelemetryTotalsLocked(build)
+	build.mu.Unlock()
+
+	data := map[string]any{
+		"agent_id":       record.AgentID,
+		"agent_role":     record.Role,
+		"telemetry_key":  key,
+		"task_id":        record.TaskID,
+		"provider":       record.Provider,
+		"model":          record.Model,
+		"capability":     record.Capability,
+		"status":         record.Status,
+		"input_tokens":   record.InputTokens,
+		"output_tokens":  record.OutputTokens,
+		"ttft_ms":        record.TimeToFirstToken.Milliseconds(),
+		"latency_ms":     record.Latency.Milliseconds(),
+		"retry_count":    len(record.RetryReasons),
+		"retry_reasons":  record.RetryReasons,
+		"cost_usd":       record.CostUSD,
+		"cost_estimated": record.CostEstimated,
+		"agent_totals":   summary,
+		"build_totals":   totals,
+	}
+	if record.ErrorCode != "" {
+		data["error_code"] = record.ErrorCode
+	}
+	am.broadcast(record.BuildID, &WSMessage{
+		Type:      WSAgentTelemetry,
+		BuildID:   record.BuildID,
+		AgentID:   record.AgentID,
+		Timestamp: record.StartedAt.Add(record.Latency),
+		Data:      data,
+	})
+}
+
+func (s *AgentTelemetrySummary) apply(record AICallTelemetry) {
+	if record.AgentID != "" {
+		s.AgentID = record.AgentID
+	}
+	if record.Role != "" {
+		s.Role = record.Role
+	}
+	if record.Provider != "" {
+		s.Provider = record.Provider
+	}
+	if record.Model != "" {
+		s.Model = record.Model
+	}
+	s.Calls++
+	if record.Status != "success" {
+		s.FailedCalls++
+	}
+	if len(record.RetryReasons) > 0 {
+		if s.RetryReasons == nil {
+			s.RetryReasons = make(map[string]int)
+		}
+		for _, reason := range record.RetryReasons {
+			s.RetryReasons[reason]++
+		}
+		s.Retries += len(record.RetryReasons)
+	}
+	s.InputTokens += record.InputTokens
+	s.OutputTokens += record.OutputTokens
+	s.CostUSD += record.CostUSD
+	s.LastLatencyMS = record.Latency.Milliseconds()
+	s.TotalLatencyMS += s.LastLatencyMS
+	s.AvgLatencyMS = s.TotalLatencyMS / int64(s.Calls)
+	if record.TimeToFirstToken > 0 {
+		s.LastTTFTMS = record.TimeToFirstToken.Milliseconds()
+	}
+	s.UpdatedAt = record.StartedAt.Add(record.Latency).UTC()
+}
+
+func buildTelemetryTotalsLocked(build *Build) map[string]any {
+	calls, inputTokens, outputTokens := 0, 0, 0
+	cost := 0.0
+	for _, summary := range build.SnapshotState.AgentTelemetry {
+		calls += summary.Calls
+		inputTokens += summary.InputTokens
+		outputTokens += summary.OutputTokens
+		cost += summary.CostUSD
+	}
+	return map[string]any{
+		"calls":         calls,
+		"input_tokens":  inputTokens,
+		"output_tokens": outputTokens,
+		"cost_usd":      cost,
+	}
+}
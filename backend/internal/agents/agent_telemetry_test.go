@@ -0,0 +1,124 @@
+package agents
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+func TestAICallTraceRecordsFirstByteUsageAndRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	request := &ai.AIRequest{Provider: ai.ProviderClaude, Model: "claude-sonnet", Capability: ai.CapabilityCodeGeneration}
+	trace := newAICallTrace(GenerateOptions{BuildID: "build-1", AgentID: "agent-1", TaskID: "task-1", RoleHint: "backend"}, request, 0.02)
+
+	req, _ := http.NewRequestWithContext(trace.withContext(t.Context()), http.MethodGet, server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+
+	record := trace.finish(&ai.AIResponse{
+		Provider: ai.ProviderClaude,
+		Content:  "done",
+		Usage:    &ai.Usage{PromptTokens: 1200, CompletionTokens: 300},
+		Metadata: map[string]interface{}{"retry_reasons": []string{"rate_limited", "network"}},
+	}, nil, 0)
+
+	if record.TimeToFirstToken < 20*time.Millisecond || record.TimeToFirstToken > record.Latency {
+		t.Fatalf("unexpected time to first token %v (latency %v)", record.TimeToFirstToken, record.Latency)
+	}
+	if record.InputTokens != 1200 || record.OutputTokens != 300 {
+		t.Fatalf("unexpected tokens %d/%d", record.InputTokens, record.OutputTokens)
+	}
+	if len(record.RetryReasons) != 2 || record.RetryReasons[0] != "rate_limited" {
+		t.Fatalf("unexpected retry reasons %v", record.RetryReasons)
+	}
+	if record.CostUSD != 0.02 || !record.CostEstimated {
+		t.Fatalf("expected estimated cost fallback, got %v (estimated=%v)", record.CostUSD, record.CostEstimated)
+	}
+
+	failed := newAICallTrace(GenerateOptions{BuildID: "build-1"}, request, 0.02).finish(nil, errors.New("RATE_LIMIT: slow down"), 0)
+	if failed.Status != "failed" || failed.ErrorCode != "rate_limited" || failed.CostUSD != 0 {
+		t.Fatalf("unexpected failed record %+v", failed)
+	}
+}
+
+func TestRecordAICallTelemetryAccumulatesPerAgentAndBroadcasts(t *testing.T) {
+	am := &AgentManager{
+		builds:      map[string]*Build{},
+		subscribers: map[string][]chan *WSMessage{},
+	}
+	build := &Build{
+		ID:     "build-telemetry",
+		Status: BuildInProgress,
+		Agents: map[string]*Agent{
+			"agent-1": {ID: "agent-1", Role: RoleFrontend, Provider: ai.ProviderGPT4},
+		},
+	}
+	am.builds[build.ID] = build
+	ch := make(chan *WSMessage, 4)
+	am.Subscribe(build.ID, ch)
+
+	started := time.Now()
+	am.RecordAICallTelemetry(AICallTelemetry{
+		BuildID: build.ID, AgentID: "agent-1", Provider: "gpt4", Model: "gpt-5", Status: "success",
+		InputTokens: 100, OutputTokens: 50, Latency: 2 * time.Second, TimeToFirstToken: 1500 * time.Millisecond,
+		CostUSD: 0.01, StartedAt: started,
+	})
+	am.RecordAICallTelemetry(AICallTelemetry{
+		BuildID: build.ID, AgentID: "agent-1", Provider: "gpt4", Model: "gpt-5", Status: "failed", ErrorCode: "timeout",
+		RetryReasons: []string{"network"}, Latency: 4 * time.Second, StartedAt: started,
+	})
+	am.RecordAICallTelemetry(AICallTelemetry{
+		BuildID: build.ID, Role: "reviewer", Status: "success", InputTokens: 10, OutputTokens: 5,
+		Latency: time.Second, CostUSD: 0.005, StartedAt: started,
+	})
+
+	build.mu.RLock()
+	summary := build.SnapshotState.AgentTelemetry["agent-1"]
+	reviewer := build.SnapshotState.AgentTelemetry["role:reviewer"]
+	build.mu.RUnlock()
+
+	if summary.Role != string(RoleFrontend) || summary.Calls != 2 || summary.FailedCalls != 1 {
+		t.Fatalf("unexpected agent summary %+v", summary)
+	}
+	if summary.InputTokens != 100 || summary.AvgLatencyMS != 3000 || summary.LastTTFTMS != 1500 {
+		t.Fatalf("unexpected agent totals %+v", summary)
+	}
+	if summary.Retries != 1 || summary.RetryReasons["network"] != 1 {
+		t.Fatalf("unexpected retry counts %+v", summary)
+	}
+	if reviewer.Calls != 1 || reviewer.CostUSD != 0.005 {
+		t.Fatalf("unexpected reviewer summary %+v", reviewer)
+	}
+
+	var last *WSMessage
+	for i := 0; i < 3; i++ {
+		select {
+		case last = <-ch:
+			if last.Type != WSAgentTelemetry {
+				t.Fatalf("expected %s, got %s", WSAgentTelemetry, last.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a telemetry broadcast per call")
+		}
+	}
+	data := last.Data.(map[string]any)
+	totals := data["build_totals"].(map[string]any)
+	if totals["calls"] != 3 || totals["input_tokens"] != 110 {
+		t.Fatalf("unexpected build totals %v", totals)
+	}
+	if len(build.ActivityTimeline) != 0 {
+		t.Fatal("telemetry should not be added to the activity timeline")
+	}
+}
@@ -96,8 +96,8 @@ var flagshipModelsByProvider = map[ai.AIProvider]map[string]bool{
 
 // selectModelForPowerMode returns the best model ID for a given provider and power mode
 func selectModelForPowerMode(provider ai.AIProvider, mode PowerMode) string {
-	if mode == "" {
-		mode = PowerFast // Default to cheapest
+	if mode == "" || mode == PowerBudget {
+		mode = PowerFast // Default to cheapest; budget mode escalates per task
 	}
 	if provider == ai.ProviderOllama {
 		if model := selectOllamaModelOverride(mode); model != "" {
@@ -460,6 +460,8 @@ type AIRouterAdapter struct {
 	router         *ai.AIRouter
 	byokManager    *ai.BYOKManager
 	budgetEnforcer *budget.BudgetEnforcer
+	telemetrySink  func(AICallTelemetry)
+	retryBudgets   func(buildID string) *ai.RetryBudget
 	startupTime    time.Time // Track when adapter was created for grace period
 }
 
@@ -487,6 +489,11 @@ func (a *AIRouterAdapter) SetBudgetEnforcer(enforcer *budget.BudgetEnforcer) {
 	a.budgetEnforcer = enforcer
 }
 
+// SetTelemetrySink receives one AICallTelemetry per provider call
+func (a *AIRouterAdapter) SetTelemetrySink(sink func(AICallTelemetry)) {
+	a.telemetrySink = sink
+}
+
 // Generate executes an AI generation request using the specified provider
 func (a *AIRouterAdapter) Generate(ctx context.Context, provider ai.AIProvider, prompt string, opts GenerateOptions) (*ai.AIResponse, error) {
 	log.Printf("AIRouterAdapter.Generate called with provider: %s", provider)
@@ -620,6 +627,7 @@ For code files, use this exact format:
 		Provider:          aiProvider,
 		CacheSystemPrompt: opts.CacheSystemPrompt,
 		DisableFallback:   opts.DisableProviderFallback,
+		RetryBudget:       a.retryBudgetFor(opts.BuildID),
 	}
 	if opts.UserID > 0 {
 		request.UserID = fmt.Sprintf("%d", opts.UserID)
@@ -697,8 +705,10 @@ For code files, use this exact format:
 		defer genCancel()
 	}
 
-	response, err := targetRouter.Generate(genCtx, request)
+	callTrace := newAICallTrace(opts, request, estimatedCost)
+	response, err := targetRouter.Generate(callTrace.withContext(genCtx), request)
 	if err != nil {
+		a.emitTelemetry(callTrace.finish(nil, err, 0))
 		plDone(0, 0, 0, 0, err)
 		finishOperation("failed", err, map[string]any{"estimated_cost_usd": estimatedCost})
 		if a.budgetEnforcer != nil && budgetReservation != nil {
@@ -716,6 +726,7 @@ For code files, use this exact format:
 
 	if response == nil || response.Content == "" {
 		emptyErr := fmt.Errorf("empty response")
+		a.emitTelemetry(callTrace.finish(response, emptyErr, 0))
 		plDone(0, 0, 0, 0, emptyErr)
 		finishOperation("failed", emptyErr, map[string]any{"estimated_cost_usd": estimatedCost})
 		if a.budgetEnforcer != nil && budgetReservation != nil {
@@ -759,6 +770,7 @@ For code files, use this exact format:
 			inTok = response.Usage.PromptTokens
 			outTok = response.Usage.CompletionTokens
 		}
+		a.emitTelemetry(callTrace.finish(response, nil, finalCost))
 		plDone(len(response.Content), inTok, outTok, finalCost, nil)
 		finishOperation("success", nil, map[string]any{
 			"response_provider":  string(actualProviderForAIResponse(response, aiProvider)),
@@ -0,0 +1,492 @@
+// approval_gates.go — Human-in-the-loop approval checkpoints.
+//
+// A build can opt into approval gates at the "plan" checkpoint (after the lead
+// agent's plan is ready, before phase tasks are queued) and the "deploy"
+// checkpoint (after generation, before final validation hands the build off
+// for preview and deployment). At a gate the build stops, broadcasts
+// build:approval-request, and waits for POST /builds/:id/approvals. Each gate
+// has its own timeout and decides whether an unanswered request approves or
+// rejects. A rejection cancels the build and keeps the files generated so far.
+package agents
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultApprovalGateTimeout = 30 * time.Minute
+	maxApprovalGateTimeout     = 24 * time.Hour
+)
+
+// normalizeBuildApprovalGates drops unknown checkpoints, keeps one gate per
+// checkpoint and fills in the timeout defaults.
+func normalizeBuildApprovalGates(gates []BuildApprovalGate) []BuildApprovalGate {
+	if len(gates) == 0 {
+		return nil
+	}
+	seen := make(map[BuildApprovalCheckpoint]bool, len(gates))
+	normalized := make([]BuildApprovalGate, 0, len(gates))
+	for _, gate := range gates {
+		checkpoint := BuildApprovalCheckpoint(strings.ToLower(strings.TrimSpace(string(gate.Checkpoint))))
+		switch checkpoint {
+		case ApprovalCheckpointPlan, ApprovalCheckpointDeploy:
+		default:
+			continue
+		}
+		if seen[checkpoint] {
+			continue
+		}
+		seen[checkpoint] = true
+
+		timeout := time.Duration(gate.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = defaultApprovalGateTimeout
+		}
+		if timeout > maxApprovalGateTimeout {
+			timeout = maxApprovalGateTimeout
+		}
+		onTimeout := ApprovalTimeoutReject
+		if BuildApprovalTimeoutAction(strings.ToLower(strings.TrimSpace(string(gate.OnTimeout)))) == ApprovalTimeoutApprove {
+			onTimeout = ApprovalTimeoutApprove
+		}
+		normalized = append(normalized, BuildApprovalGate{
+			Checkpoint:     checkpoint,
+			TimeoutSeconds: int(timeout / time.Second),
+			OnTimeout:      onTimeout,
+		})
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+func approvalGateForLocked(build *Build, checkpoint BuildApprovalCheckpoint) *BuildApprovalGate {
+	if build == nil {
+		return nil
+	}
+	for idx := range build.ApprovalGates {
+		if build.ApprovalGates[idx].Checkpoint == checkpoint {
+			return &build.ApprovalGates[idx]
+		}
+	}
+	return nil
+}
+
+// latestApprovalRequestIndexLocked returns the most recent request for the
+// checkpoint, or -1.
+func latestApprovalRequestIndexLocked(build *Build, checkpoint BuildApprovalCheckpoint) int {
+	if build == nil {
+		return -1
+	}
+	for idx := len(build.Interaction.ApprovalRequests) - 1; idx >= 0; idx-- {
+		if build.Interaction.ApprovalRequests[idx].Checkpoint == checkpoint {
+			return idx
+		}
+	}
+	return -1
+}
+
+// pendingApprovalRequestIndexLocked finds the pending request with the given
+// ID, or any pending request when requestID is empty.
+func pendingApprovalRequestIndexLocked(build *Build, requestID string) int {
+	if build == nil {
+		return -1
+	}
+	for idx, request := range build.Interaction.ApprovalRequests {
+		if request.Status != ApprovalRequestPending {
+			continue
+		}
+		if requestID == "" || request.ID == requestID {
+			return idx
+		}
+	}
+	return -1
+}
+
+// approvalRequestAllowsProgress reports whether the build may continue past
+// the request's checkpoint.
+func approvalRequestAllowsProgress(request BuildApprovalRequest) bool {
+	switch request.Status {
+	case ApprovalRequestApproved:
+		return true
+	case ApprovalRequestTimedOut:
+		return request.OnTimeout == ApprovalTimeoutApprove
+	default:
+		return false
+	}
+}
+
+func approvalStatusFromApprovalRequest(request BuildApprovalRequest) BuildApprovalStatus {
+	switch {
+	case request.Status == ApprovalRequestPending:
+		return ApprovalStatusPending
+	case approvalRequestAllowsProgress(request):
+		return ApprovalStatusSatisfied
+	default:
+		return ApprovalStatusDenied
+	}
+}
+
+func approvalCheckpointTitle(checkpoint BuildApprovalCheckpoint) string {
+	switch checkpoint {
+	case ApprovalCheckpointPlan:
+		return "Build plan approval"
+	case ApprovalCheckpointDeploy:
+		return "Deployment approval"
+	default:
+		return "Build approval"
+	}
+}
+
+func planApprovalSummary(plan *BuildPlan) string {
+	if plan == nil {
+		return "The lead agent finished planning. Approve to start building, edit the plan, or reject to stop."
+	}
+	stack := make([]string, 0, 3)
+	for _, part := range []string{plan.TechStack.Frontend, plan.TechStack.Backend, plan.TechStack.Database} {
+		if strings.TrimSpace(part) != "" {
+			stack = append(stack, strings.TrimSpace(part))
+		}
+	}
+	stackLabel := "an unspecified stack"
+	if len(stack) > 0 {
+		stackLabel = strings.Join(stack, " + ")
+	}
+	return fmt.Sprintf("Plan ready: %d files, %d features and %d API endpoints on %s. Approve to start building, edit the plan, or reject to stop.",
+		len(plan.Files), len(plan.Features), len(plan.APIEndpoints), stackLabel)
+}
+
+// holdAtApprovalGate opens an approval request when the build has a gate at
+// checkpoint that has not been decided yet. It returns true while the build
+// must stay where it is. proceed runs on its own goroutine once the request
+// is approved, or times out into approval.
+func (am *AgentManager) holdAtApprovalGate(build *Build, checkpoint BuildApprovalCheckpoint, summary string, proceed func()) bool {
+	if build == nil {
+		return false
+	}
+
+	now := time.Now().UTC()
+	build.mu.Lock()
+	gate := approvalGateForLocked(build, checkpoint)
+	if gate == nil {
+		build.mu.Unlock()
+		return false
+	}
+	if idx := latestApprovalRequestIndexLocked(build, checkpoint); idx >= 0 {
+		request := build.Interaction.ApprovalRequests[idx]
+		build.mu.Unlock()
+		return !approvalRequestAllowsProgress(request)
+	}
+
+	request := BuildApprovalRequest{
+		ID:          uuid.New().String(),
+		Checkpoint:  checkpoint,
+		Status:      ApprovalRequestPending,
+		Title:       approvalCheckpointTitle(checkpoint),
+		Summary:     strings.TrimSpace(summary),
+		OnTimeout:   gate.OnTimeout,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(time.Duration(gate.TimeoutSeconds) * time.Second),
+	}
+	build.Interaction.ApprovalRequests = append(build.Interaction.ApprovalRequests, request)
+	appendBuildApprovalEventLocked(build, BuildApprovalEvent{
+		Kind:       fmt.Sprintf("%s_approval", checkpoint),
+		Title:      request.Title,
+		Status:     ApprovalEventPending,
+		Summary:    request.Summary,
+		SourceType: "approval_gate",
+		SourceID:   request.ID,
+		Actor:      "user",
+		Timestamp:  now,
+	})
+	appendBuildConversationMessageLocked(build, BuildConversationMessage{
+		Role:             ConversationRoleSystem,
+		Kind:             ConversationKindDirective,
+		Content:          fmt.Sprintf("%s required: %s", request.Title, request.Summary),
+		RequiresResponse: true,
+		Blocking:         true,
+		Timestamp:        now,
+	})
+	build.UpdatedAt = now
+	resolveWaitingStateLocked(build)
+	refreshDerivedSnapshotStateLocked(build, &build.SnapshotState)
+	interaction := copyBuildInteractionStateLocked(build)
+	data := map[string]any{
+		"request":     request,
+		"interaction": interaction,
+	}
+	if checkpoint == ApprovalCheckpointPlan {
+		data["plan"] = buildPlanOutlineLocked(build)
+	}
+	build.mu.Unlock()
+
+	am.persistBuildSnapshot(build, nil)
+	am.broadcast(build.ID, &WSMessage{
+		Type:      WSBuildApprovalRequest,
+		BuildID:   build.ID,
+		Timestamp: now,
+		Data:      data,
+	})
+	am.broadcastInteractionUpdate(build.ID, interaction)
+	log.Printf("Build %s: %s gate — waiting for approval until %s", build.ID, checkpoint, request.ExpiresAt.Format(time.RFC3339))
+
+	go am.awaitApprovalDecision(build, request.ID, proceed)
+	return true
+}
+
+// holdPlanForApproval stops handlePlanCompletion at the plan gate. The plan is
+// stored on the build while it waits so the user can review or edit it; once
+// approved, planning completion runs again with the (possibly edited) plan.
+func (am *AgentManager) holdPlanForApproval(build *Build, output *TaskOutput) bool {
+	build.mu.Lock()
+	undecided := approvalGateForLocked(build, ApprovalCheckpointPlan) != nil &&
+		latestApprovalRequestIndexLocked(build, ApprovalCheckpointPlan) < 0
+	if undecided && output != nil && output.Plan != nil {
+		build.Plan = output.Plan
+	}
+	summary := planApprovalSummary(build.Plan)
+	build.mu.Unlock()
+
+	return am.holdAtApprovalGate(build, ApprovalCheckpointPlan, summary, func() {
+		resumed := &TaskOutput{}
+		if output != nil {
+			outputCopy := *output
+			resumed = &outputCopy
+		}
+		build.mu.RLock()
+		resumed.Plan = build.Plan
+		build.mu.RUnlock()
+
+		am.handlePlanCompletion(build, resumed)
+		am.updateBuildProgress(build)
+		am.checkBuildCompletion(build)
+	})
+}
+
+// holdDeployForApproval stops finalizePhasedPipeline at the deploy gate and
+// finalizes again once the user approves.
+func (am *AgentManager) holdDeployForApproval(build *Build) bool {
+	build.mu.RLock()
+	undecided := approvalGateForLocked(build, ApprovalCheckpointDeploy) != nil &&
+		latestApprovalRequestIndexLocked(build, ApprovalCheckpointDeploy) < 0
+	build.mu.RUnlock()
+
+	summary := ""
+	if undecided {
+		summary = fmt.Sprintf("Generation finished with %d files. Approve to run final validation and hand the build off for preview and deployment, or reject to stop here.",
+			len(am.collectGeneratedFiles(build)))
+	}
+	return am.holdAtApprovalGate(build, ApprovalCheckpointDeploy, summary, func() {
+		am.finalizePhasedPipeline(build)
+	})
+}
+
+// awaitApprovalDecision polls the request until it is decided or expires,
+// the same way waitForFrontendUIApproval waits on the frontend gate.
+func (am *AgentManager) awaitApprovalDecision(build *Build, requestID string, proceed func()) {
+	var done <-chan struct{}
+	if am.ctx != nil {
+		done = am.ctx.Done()
+	}
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		build.mu.RLock()
+		status := build.Status
+		var request *BuildApprovalRequest
+		for idx := range build.Interaction.ApprovalRequests {
+			if build.Interaction.ApprovalRequests[idx].ID == requestID {
+				copyRequest := build.Interaction.ApprovalRequests[idx]
+				request = &copyRequest
+				break
+			}
+		}
+		build.mu.RUnlock()
+
+		if request == nil || status == BuildCompleted || status == BuildFailed || status == BuildCancelled {
+			return
+		}
+		if request.Status == ApprovalRequestPending {
+			if time.Now().Before(request.ExpiresAt) {
+				continue
+			}
+			expired, err := am.expireApprovalRequest(build, requestID)
+			if err != nil || expired == nil {
+				continue
+			}
+			request = expired
+		}
+		if approvalRequestAllowsProgress(*request) {
+			log.Printf("Build %s: %s gate %s — continuing", build.ID, request.Checkpoint, request.Status)
+			if proceed != nil {
+				proceed()
+			}
+		}
+		return
+	}
+}
+
+// expireApprovalRequest applies the gate's timeout action to a request that
+// was not answered in time.
+func (am *AgentManager) expireApprovalRequest(build *Build, requestID string) (*BuildApprovalRequest, error) {
+	now := time.Now().UTC()
+	build.mu.Lock()
+	idx := pendingApprovalRequestIndexLocked(build, requestID)
+	if idx < 0 {
+		build.mu.Unlock()
+		return nil, fmt.Errorf("approval request %s is not pending", requestID)
+	}
+	request := &build.Interaction.ApprovalRequests[idx]
+	request.Status = ApprovalRequestTimedOut
+	request.ResolvedBy = "timeout"
+	request.ResolvedAt = &now
+	request.ResolutionNote = fmt.Sprintf("No decision before the deadline; the gate's timeout action is %s.", request.OnTimeout)
+	resolved := *request
+	interaction := am.recordApprovalResolutionLocked(build, resolved, now)
+	build.mu.Unlock()
+
+	am.finishApprovalResolution(build, resolved, interaction)
+	return &resolved, nil
+}
+
+// ResolveApprovalRequest approves or rejects a pending approval request. When
+// requestID is empty the build's pending request is used. Approving the plan
+// checkpoint may replace the plan with an edited one before phase tasks are
+// queued.
+func (am *AgentManager) ResolveApprovalRequest(buildID string, requestID string, approve bool, note string, plan *BuildPlan) (BuildInteractionState, *BuildApprovalRequest, error) {
+	build, err := am.GetBuild(buildID)
+	if err != nil {
+		return BuildInteractionState{}, nil, err
+	}
+
+	now := time.Now().UTC()
+	build.mu.Lock()
+	if build.Status == BuildCompleted || build.Status == BuildFailed || build.Status == BuildCancelled {
+		build.mu.Unlock()
+		return BuildInteractionState{}, nil, fmt.Errorf("build %s already in terminal state: %s", buildID, build.Status)
+	}
+	idx := pendingApprovalRequestIndexLocked(build, strings.TrimSpace(requestID))
+	if idx < 0 {
+		build.mu.Unlock()
+		if strings.TrimSpace(requestID) == "" {
+			return BuildInteractionState{}, nil, fmt.Errorf("build %s has no pending approval request", buildID)
+		}
+		return BuildInteractionState{}, nil, fmt.Errorf("approval request %s not found", requestID)
+	}
+	request := &build.Interaction.ApprovalRequests[idx]
+	if plan != nil {
+		if !approve || request.Checkpoint != ApprovalCheckpointPlan {
+			build.mu.Unlock()
+			return BuildInteractionState{}, nil, fmt.Errorf("an edited plan can only be submitted when approving the plan checkpoint")
+		}
+		edited := cloneBuildPlan(plan)
+		edited.BuildID = build.ID
+		if build.Plan != nil && strings.TrimSpace(edited.ID) == "" {
+			edited.ID = build.Plan.ID
+		}
+		build.Plan = edited
+		request.PlanEdited = true
+	}
+	request.Status = ApprovalRequestRejected
+	if approve {
+		request.Status = ApprovalRequestApproved
+	}
+	request.ResolvedBy = "user"
+	request.ResolvedAt = &now
+	request.ResolutionNote = strings.TrimSpace(note)
+	resolved := *request
+	interaction := am.recordApprovalResolutionLocked(build, resolved, now)
+	build.mu.Unlock()
+
+	am.finishApprovalResolution(build, resolved, interaction)
+	return interaction, &resolved, nil
+}
+
+// recordApprovalResolutionLocked logs the decision on the interaction state
+// and returns a copy of it for broadcasting.
+func (am *AgentManager) recordApprovalResolutionLocked(build *Build, request BuildApprovalRequest, now time.Time) BuildInteractionState {
+	eventStatus := ApprovalEventDenied
+	if approvalRequestAllowsProgress(request) {
+		eventStatus = ApprovalEventSatisfied
+	}
+	appendBuildApprovalEventLocked(build, BuildApprovalEvent{
+		Kind:       fmt.Sprintf("%s_approval", request.Checkpoint),
+		Title:      request.Title,
+		Status:     eventStatus,
+		Summary:    request.ResolutionNote,
+		SourceType: "approval_gate",
+		SourceID:   request.ID,
+		Actor:      request.ResolvedBy,
+		Timestamp:  now,
+	})
+	content := fmt.Sprintf("%s %s", request.Title, request.Status)
+	if request.PlanEdited {
+		content += " with an edited plan"
+	}
+	appendBuildConversationMessageLocked(build, BuildConversationMessage{
+		Role:      ConversationRoleSystem,
+		Kind:      ConversationKindDirective,
+		Content:   content,
+		Timestamp: now,
+	})
+	if note := strings.TrimSpace(request.ResolutionNote); note != "" && request.ResolvedBy == "user" && approvalRequestAllowsProgress(request) {
+		appendSteeringNoteLocked(build, fmt.Sprintf("User note at the %s approval gate: %s", request.Checkpoint, note))
+	}
+	build.UpdatedAt = now
+	resolveWaitingStateLocked(build)
+	refreshDerivedSnapshotStateLocked(build, &build.SnapshotState)
+	return copyBuildInteractionStateLocked(build)
+}
+
+// finishApprovalResolution broadcasts a decided request and cancels the build
+// when the decision stops it.
+func (am *AgentManager) finishApprovalResolution(build *Build, request BuildApprovalRequest, interaction BuildInteractionState) {
+	am.persistBuildSnapshot(build, nil)
+	am.broadcast(build.ID, &WSMessage{
+		Type:      WSBuildApprovalUpdate,
+		BuildID:   build.ID,
+		Timestamp: time.Now().UTC(),
+		Data: map[string]any{
+			"request":     request,
+			"interaction": interaction,
+		},
+	})
+	am.broadcastInteractionUpdate(build.ID, interaction)
+
+	if !approvalRequestAllowsProgress(request) {
+		reason := fmt.Sprintf("cancelled: %s was rejected", strings.ToLower(request.Title))
+		if request.Status == ApprovalRequestTimedOut {
+			reason = fmt.Sprintf("cancelled: %s timed out", strings.ToLower(request.Title))
+		}
+		if err := am.cancelBuildWithReason(build.ID, reason); err != nil {
+			log.Printf("Build %s: could not cancel after %s gate decision: %v", build.ID, request.Checkpoint, err)
+		}
+	}
+}
+
+// GetApprovalRequests returns the build's configured gates and its approval
+// requests, newest last.
+func (am *AgentManager) GetApprovalRequests(buildID string) ([]BuildApprovalGate, []BuildApprovalRequest, error) {
+	build, err := am.GetBuild(buildID)
+	if err != nil {
+		return nil, nil, err
+	}
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+	gates := append([]BuildApprovalGate(nil), build.ApprovalGates...)
+	requests := append([]BuildApprovalRequest(nil), build.Interaction.ApprovalRequests...)
+	return gates, requests, nil
+}
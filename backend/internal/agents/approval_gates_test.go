@@ -0,0 +1,153 @@
+package agents
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeBuildApprovalGates(t *testing.T) {
+	gates := normalizeBuildApprovalGates([]BuildApprovalGate{
+		{Checkpoint: " Plan ", OnTimeout: "APPROVE"},
+		{Checkpoint: "plan", TimeoutSeconds: 5},
+		{Checkpoint: "deploy", TimeoutSeconds: 3 * 24 * 60 * 60, OnTimeout: "ignore"},
+		{Checkpoint: "review"},
+	})
+	if len(gates) != 2 {
+		t.Fatalf("expected plan and deploy gates, got %+v", gates)
+	}
+	if gates[0].Checkpoint != ApprovalCheckpointPlan || gates[0].TimeoutSeconds != 1800 || gates[0].OnTimeout != ApprovalTimeoutApprove {
+		t.Fatalf("unexpected plan gate %+v", gates[0])
+	}
+	if gates[1].Checkpoint != ApprovalCheckpointDeploy || gates[1].TimeoutSeconds != 86400 || gates[1].OnTimeout != ApprovalTimeoutReject {
+		t.Fatalf("unexpected deploy gate %+v", gates[1])
+	}
+	if normalizeBuildApprovalGates([]BuildApprovalGate{{Checkpoint: "unknown"}}) != nil {
+		t.Fatal("expected unknown checkpoints to be dropped")
+	}
+}
+
+func newApprovalGateTestManager(gates ...BuildApprovalGate) (*AgentManager, *Build, chan *WSMessage) {
+	am := &AgentManager{
+		builds:      map[string]*Build{},
+		subscribers: map[string][]chan *WSMessage{},
+	}
+	build := &Build{
+		ID:            "build-approvals",
+		UserID:        1,
+		Status:        BuildPlanning,
+		Agents:        map[string]*Agent{},
+		ApprovalGates: gates,
+		Plan:          &BuildPlan{ID: "plan-1", Files: []PlannedFile{{Path: "src/App.tsx"}}},
+	}
+	am.builds[build.ID] = build
+	ch := make(chan *WSMessage, 32)
+	am.Subscribe(build.ID, ch)
+	return am, build, ch
+}
+
+func waitForWSMessage(t *testing.T, ch chan *WSMessage, msgType WSMessageType) *WSMessage {
+	t.Helper()
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for %s", msgType)
+			return nil
+		}
+	}
+}
+
+func TestPlanApprovalGateWaitsForUserAndAcceptsEditedPlan(t *testing.T) {
+	am, build, ch := newApprovalGateTestManager(BuildApprovalGate{Checkpoint: ApprovalCheckpointPlan, TimeoutSeconds: 600, OnTimeout: ApprovalTimeoutReject})
+
+	proceeded := make(chan struct{})
+	if !am.holdAtApprovalGate(build, ApprovalCheckpointPlan, "Plan ready", func() { close(proceeded) }) {
+		t.Fatal("expected the plan gate to hold the build")
+	}
+	waitForWSMessage(t, ch, WSBuildApprovalRequest)
+
+	build.mu.RLock()
+	waiting := build.Interaction.WaitingForUser
+	requests := append([]BuildApprovalRequest(nil), build.Interaction.ApprovalRequests...)
+	build.mu.RUnlock()
+	if !waiting || len(requests) != 1 || requests[0].Status != ApprovalRequestPending {
+		t.Fatalf("expected one pending request and a waiting build, got waiting=%v requests=%+v", waiting, requests)
+	}
+
+	// A second pass through the gate while pending must not open another request.
+	if !am.holdAtApprovalGate(build, ApprovalCheckpointPlan, "Plan ready", nil) {
+		t.Fatal("expected the gate to keep holding while the request is pending")
+	}
+
+	if _, _, err := am.ResolveApprovalRequest(build.ID, requests[0].ID, false, "", &BuildPlan{}); err == nil {
+		t.Fatal("expected an edited plan to be refused on rejection")
+	}
+
+	edited := &BuildPlan{Files: []PlannedFile{{Path: "src/Main.tsx"}}, TechStack: TechStack{Frontend: "Vue"}}
+	interaction, resolved, err := am.ResolveApprovalRequest(build.ID, "", true, "Use Vue instead", edited)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved.Status != ApprovalRequestApproved || !resolved.PlanEdited || resolved.ResolvedBy != "user" {
+		t.Fatalf("unexpected resolved request %+v", resolved)
+	}
+	if interaction.WaitingForUser {
+		t.Fatal("expected the build to stop waiting once approved")
+	}
+	waitForWSMessage(t, ch, WSBuildApprovalUpdate)
+
+	select {
+	case <-proceeded:
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the build to continue after approval")
+	}
+
+	build.mu.RLock()
+	plan, status, notes := build.Plan, build.Status, len(build.Interaction.SteeringNotes)
+	build.mu.RUnlock()
+	if plan.ID != "plan-1" || plan.BuildID != build.ID || plan.Files[0].Path != "src/Main.tsx" {
+		t.Fatalf("expected the edited plan to replace the original, got %+v", plan)
+	}
+	if status == BuildCancelled {
+		t.Fatal("approved build should not be cancelled")
+	}
+	if notes == 0 {
+		t.Fatal("expected the approval note to reach the agents")
+	}
+	if am.holdAtApprovalGate(build, ApprovalCheckpointPlan, "Plan ready", nil) {
+		t.Fatal("expected an approved gate to let the build through")
+	}
+}
+
+func TestDeployApprovalGateTimeoutRejectCancelsBuild(t *testing.T) {
+	am, build, ch := newApprovalGateTestManager(BuildApprovalGate{Checkpoint: ApprovalCheckpointDeploy, TimeoutSeconds: 1, OnTimeout: ApprovalTimeoutReject})
+	build.Status = BuildInProgress
+
+	proceeded := make(chan struct{}, 1)
+	if !am.holdAtApprovalGate(build, ApprovalCheckpointDeploy, "Generation finished", func() { proceeded <- struct{}{} }) {
+		t.Fatal("expected the deploy gate to hold the build")
+	}
+
+	update := waitForWSMessage(t, ch, WSBuildApprovalUpdate)
+	request := update.Data.(map[string]any)["request"].(map[string]any)
+	if request["status"] != string(ApprovalRequestTimedOut) || request["resolved_by"] != "timeout" {
+		t.Fatalf("expected a timed out request, got %+v", request)
+	}
+	waitForWSMessage(t, ch, WSBuildFSMCancelled)
+
+	build.mu.RLock()
+	status, buildErr := build.Status, build.Error
+	build.mu.RUnlock()
+	if status != BuildCancelled || buildErr != "cancelled: deployment approval timed out" {
+		t.Fatalf("expected the build to be cancelled by the timeout, got %s (%q)", status, buildErr)
+	}
+	select {
+	case <-proceeded:
+		t.Fatal("a rejected gate must not continue the build")
+	default:
+	}
+}
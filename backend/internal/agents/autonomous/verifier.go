@@ -165,13 +165,22 @@ func (bv *BuildVerifier) runPipeline(ctx context.Context) []VerifyStep {
 	case "java-gradle":
 		steps = append(steps, bv.stepInstallDeps(ctx, "gradle", "dependencies", "-q"))
 		steps = append(steps, bv.stepSecurityAudit(ctx, projectType))
-		steps = append(steps, bv.stepBuild(ctx, "gradle", "compileJava", "-q"))
+		// classes compiles Java and Kotlin sources alike.
+		steps = append(steps, bv.stepBuild(ctx, "gradle", "classes", "-q"))
 		steps = append(steps, bv.stepTest(ctx, "gradle", "test", "-q"))
 	case "dotnet":
 		steps = append(steps, bv.stepInstallDeps(ctx, "dotnet", "restore"))
 		steps = append(steps, bv.stepSecurityAudit(ctx, projectType))
 		steps = append(steps, bv.stepBuild(ctx, "dotnet", "build", "--no-restore"))
 		steps = append(steps, bv.stepTest(ctx, "dotnet", "test", "--no-build"))
+	case "swift":
+		steps = append(steps, bv.stepInstallDeps(ctx, "swift", "package", "resolve"))
+		steps = append(steps, bv.stepBuild(ctx, "swift", "build"))
+		steps = append(steps, bv.stepTest(ctx, "swift", "test"))
+	case "elixir":
+		steps = append(steps, bv.stepInstallDeps(ctx, "mix", "deps.get"))
+		steps = append(steps, bv.stepBuild(ctx, "mix", "compile"))
+		steps = append(steps, bv.stepTest(ctx, "mix", "test"))
 	default:
 		steps = append(steps, VerifyStep{
 			Name:   "detect",
@@ -607,6 +616,16 @@ func (bv *BuildVerifier) detectProjectType() string {
 	if len(matches) > 0 {
 		return "dotnet"
 	}
+	matches, _ = filepath.Glob(filepath.Join(bv.workDir, "*.sln"))
+	if len(matches) > 0 {
+		return "dotnet"
+	}
+	if _, err := os.Stat(filepath.Join(bv.workDir, "Package.swift")); err == nil {
+		return "swift"
+	}
+	if _, err := os.Stat(filepath.Join(bv.workDir, "mix.exs")); err == nil {
+		return "elixir"
+	}
 	return "unknown"
 }
 
@@ -0,0 +1,41 @@
+package agents
+
+import (
+	"time"
+
+	"apex-build/pkg/models"
+)
+
+// BuildActivitySink receives builds of a project that reached a final
+// status, for the project's activity feed. The same build may be reported
+// more than once. Implemented in main.go over activity.Service; wired via
+// SetBuildActivitySink. Implementations must not block.
+type BuildActivitySink interface {
+	BuildFinished(projectID, userID uint, buildID, status, description string, at time.Time)
+}
+
+// SetBuildActivitySink reports finished builds to sink.
+func (am *AgentManager) SetBuildActivitySink(sink BuildActivitySink) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.buildActivity = sink
+}
+
+// reportBuildActivity hands a persisted snapshot to the activity sink once
+// its build is attached to a project and no longer running.
+func (am *AgentManager) reportBuildActivity(snapshot *models.CompletedBuild) {
+	// Wired once at startup; read without am.mu because snapshots are
+	// persisted from paths that may already hold it.
+	sink := am.buildActivity
+	if sink == nil || snapshot == nil || snapshot.ProjectID == nil || *snapshot.ProjectID == 0 {
+		return
+	}
+	if isActiveBuildStatus(snapshot.Status) {
+		return
+	}
+	at := snapshot.UpdatedAt
+	if snapshot.CompletedAt != nil {
+		at = *snapshot.CompletedAt
+	}
+	sink.BuildFinished(*snapshot.ProjectID, snapshot.UserID, snapshot.BuildID, snapshot.Status, snapshot.Description, at)
+}
@@ -0,0 +1,299 @@
+// build_compare.go — Side-by-side comparison of two finished builds.
+//
+// Users often rerun the same prompt in a different mode (fast vs. max) and
+// want to see what changed before keeping one. Two builds are comparable when
+// they belong to the same project or were started from the same description.
+// The comparison covers generated files (added / removed / changed, with
+// unified diffs), spend and validation outcome.
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	buildCompareContextLines = 3
+	// Larger diffs are truncated; the line counts still cover the whole file.
+	buildCompareMaxDiffBytes = 64 * 1024
+)
+
+// BuildFileDiff describes how one file differs between two builds
+type BuildFileDiff struct {
+	Path         string `json:"path"`
+	Status       string `json:"status"` // added, removed, changed
+	LinesAdded   int    `json:"lines_added"`
+	LinesRemoved int    `json:"lines_removed"`
+	Diff         string `json:"diff,omitempty"`
+	Truncated    bool   `json:"truncated,omitempty"`
+}
+
+// BuildComparisonValidation summarizes how far a build got through validation
+type BuildComparisonValidation struct {
+	Status                    string `json:"status"`
+	Error                     string `json:"error,omitempty"`
+	QualityGateStatus         string `json:"quality_gate_status,omitempty"`
+	QualityGateStage          string `json:"quality_gate_stage,omitempty"`
+	CompileValidationPassed   bool   `json:"compile_validation_passed"`
+	CompileValidationAttempts int    `json:"compile_validation_attempts,omitempty"`
+	CompileValidationRepairs  int    `json:"compile_validation_repairs,omitempty"`
+	Blockers                  int    `json:"blockers,omitempty"`
+}
+
+// BuildComparisonSide is one of the two builds being compared
+type BuildComparisonSide struct {
+	BuildID      string                    `json:"build_id"`
+	ProjectID    *uint                     `json:"project_id,omitempty"`
+	Mode         string                    `json:"mode"`
+	PowerMode    string                    `json:"power_mode"`
+	FilesCount   int                       `json:"files_count"`
+	TotalCost    float64                   `json:"total_cost"`
+	DurationMs   int64                     `json:"duration_ms"`
+	AICalls      int                       `json:"ai_calls"`
+	InputTokens  int                       `json:"input_tokens"`
+	OutputTokens int                       `json:"output_tokens"`
+	CompletedAt  *time.Time                `json:"completed_at,omitempty"`
+	Validation   BuildComparisonValidation `json:"validation"`
+}
+
+// BuildComparisonSummary counts files by outcome
+type BuildComparisonSummary struct {
+	Added        int `json:"added"`
+	Removed      int `json:"removed"`
+	Changed      int `json:"changed"`
+	Unchanged    int `json:"unchanged"`
+	LinesAdded   int `json:"lines_added"`
+	LinesRemoved int `json:"lines_removed"`
+}
+
+// BuildComparisonCost is head minus base for spend and duration
+type BuildComparisonCost struct {
+	CostDeltaUSD      float64 `json:"cost_delta_usd"`
+	DurationDeltaMs   int64   `json:"duration_delta_ms"`
+	InputTokensDelta  int     `json:"input_tokens_delta"`
+	OutputTokensDelta int     `json:"output_tokens_delta"`
+	CheaperBuildID    string  `json:"cheaper_build_id,omitempty"`
+	FasterBuildID     string  `json:"faster_build_id,omitempty"`
+}
+
+// BuildComparison is the result of comparing base against head
+type BuildComparison struct {
+	MatchedBy string                 `json:"matched_by"` // project or description
+	Base      BuildComparisonSide    `json:"base"`
+	Head      BuildComparisonSide    `json:"head"`
+	Summary   BuildComparisonSummary `json:"summary"`
+	Cost      BuildComparisonCost    `json:"cost"`
+	Files     []BuildFileDiff        `json:"files"`
+	// ValidatedBuildIDs lists the builds that completed with no failed
+	// quality gate and no open blockers.
+	ValidatedBuildIDs []string `json:"validated_build_ids"`
+}
+
+// buildComparisonMatch reports why two builds may be compared, or "" if they
+// are unrelated.
+func buildComparisonMatch(base, head *models.CompletedBuild) string {
+	if base.ProjectID != nil && head.ProjectID != nil && *base.ProjectID == *head.ProjectID {
+		return "project"
+	}
+	normalize := func(description string) string {
+		return strings.ToLower(strings.Join(strings.Fields(description), " "))
+	}
+	if desc := normalize(base.Description); desc != "" && desc == normalize(head.Description) {
+		return "description"
+	}
+	return ""
+}
+
+func buildComparisonSideFromSnapshot(snapshot *models.CompletedBuild) BuildComparisonSide {
+	state := parseBuildSnapshotState(snapshot.StateJSON)
+	side := BuildComparisonSide{
+		BuildID:     snapshot.BuildID,
+		ProjectID:   snapshot.ProjectID,
+		Mode:        snapshot.Mode,
+		PowerMode:   snapshot.PowerMode,
+		FilesCount:  snapshot.FilesCount,
+		TotalCost:   snapshot.TotalCost,
+		DurationMs:  snapshot.DurationMs,
+		CompletedAt: snapshot.CompletedAt,
+		Validation: BuildComparisonValidation{
+			Status:            snapshot.Status,
+			Error:             snapshot.Error,
+			QualityGateStatus: state.QualityGateStatus,
+			QualityGateStage:  state.QualityGateStage,
+			Blockers:          len(state.Blockers),
+		},
+	}
+	for _, summary := range state.AgentTelemetry {
+		side.AICalls += summary.Calls
+		side.InputTokens += summary.InputTokens
+		side.OutputTokens += summary.OutputTokens
+	}
+	if restore := state.RestoreContext; restore != nil {
+		side.Validation.CompileValidationPassed = restore.CompileValidationPassed
+		side.Validation.CompileValidationAttempts = restore.CompileValidationAttempts
+		side.Validation.CompileValidationRepairs = restore.CompileValidationRepairs
+	}
+	return side
+}
+
+func (side BuildComparisonSide) validated() bool {
+	return side.Validation.Status == string(BuildCompleted) &&
+		side.Validation.QualityGateStatus != "failed" &&
+		side.Validation.Blockers == 0
+}
+
+// diffBuildFiles compares two file sets by path. Paths are sorted so the
+// result is stable across calls.
+func diffBuildFiles(base, head []GeneratedFile) ([]BuildFileDiff, BuildComparisonSummary) {
+	baseByPath := make(map[string]string, len(base))
+	for _, file := range base {
+		baseByPath[normalizeOwnedPath(file.Path)] = file.Content
+	}
+	headByPath := make(map[string]string, len(head))
+	for _, file := range head {
+		headByPath[normalizeOwnedPath(file.Path)] = file.Content
+	}
+
+	paths := make([]string, 0, len(baseByPath)+len(headByPath))
+	for path := range baseByPath {
+		paths = append(paths, path)
+	}
+	for path := range headByPath {
+		if _, ok := baseByPath[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	diffs := make([]BuildFileDiff, 0)
+	var summary BuildComparisonSummary
+	for _, path := range paths {
+		before, inBase := baseByPath[path]
+		after, inHead := headByPath[path]
+		status := "changed"
+		switch {
+		case !inBase:
+			status = "added"
+			summary.Added++
+		case !inHead:
+			status = "removed"
+			summary.Removed++
+		case before == after:
+			summary.Unchanged++
+			continue
+		default:
+			summary.Changed++
+		}
+
+		diff := unifiedFileDiff(path, before, after, inBase, inHead)
+		summary.LinesAdded += diff.LinesAdded
+		summary.LinesRemoved += diff.LinesRemoved
+		diff.Status = status
+		diffs = append(diffs, diff)
+	}
+	return diffs, summary
+}
+
+func unifiedFileDiff(path, before, after string, inBase, inHead bool) BuildFileDiff {
+	fromFile, toFile := "a/"+path, "b/"+path
+	if !inBase {
+		fromFile = "/dev/null"
+	}
+	if !inHead {
+		toFile = "/dev/null"
+	}
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  buildCompareContextLines,
+	})
+	diff := BuildFileDiff{Path: path}
+	if err != nil {
+		return diff
+	}
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			diff.LinesAdded++
+		case strings.HasPrefix(line, "-"):
+			diff.LinesRemoved++
+		}
+	}
+	if len(text) > buildCompareMaxDiffBytes {
+		cut := strings.LastIndex(text[:buildCompareMaxDiffBytes], "\n")
+		if cut < 0 {
+			cut = buildCompareMaxDiffBytes
+		}
+		text = text[:cut+1]
+		diff.Truncated = true
+	}
+	diff.Diff = text
+	return diff
+}
+
+// CompareBuildSnapshots diffs head against base. It refuses builds that share
+// neither a project nor a description.
+func CompareBuildSnapshots(base, head *models.CompletedBuild) (*BuildComparison, error) {
+	if base == nil || head == nil {
+		return nil, fmt.Errorf("both builds are required")
+	}
+	if base.BuildID == head.BuildID {
+		return nil, fmt.Errorf("cannot compare a build with itself")
+	}
+	matchedBy := buildComparisonMatch(base, head)
+	if matchedBy == "" {
+		return nil, fmt.Errorf("builds %s and %s do not share a project or description", base.BuildID, head.BuildID)
+	}
+
+	baseFiles, err := parseBuildFiles(base.FilesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("read files for build %s: %w", base.BuildID, err)
+	}
+	headFiles, err := parseBuildFiles(head.FilesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("read files for build %s: %w", head.BuildID, err)
+	}
+
+	comparison := &BuildComparison{
+		MatchedBy: matchedBy,
+		Base:      buildComparisonSideFromSnapshot(base),
+		Head:      buildComparisonSideFromSnapshot(head),
+	}
+	comparison.Files, comparison.Summary = diffBuildFiles(baseFiles, headFiles)
+
+	cost := BuildComparisonCost{
+		CostDeltaUSD:      comparison.Head.TotalCost - comparison.Base.TotalCost,
+		DurationDeltaMs:   comparison.Head.DurationMs - comparison.Base.DurationMs,
+		InputTokensDelta:  comparison.Head.InputTokens - comparison.Base.InputTokens,
+		OutputTokensDelta: comparison.Head.OutputTokens - comparison.Base.OutputTokens,
+	}
+	switch {
+	case cost.CostDeltaUSD < 0:
+		cost.CheaperBuildID = head.BuildID
+	case cost.CostDeltaUSD > 0:
+		cost.CheaperBuildID = base.BuildID
+	}
+	switch {
+	case cost.DurationDeltaMs < 0:
+		cost.FasterBuildID = head.BuildID
+	case cost.DurationDeltaMs > 0:
+		cost.FasterBuildID = base.BuildID
+	}
+	comparison.Cost = cost
+	comparison.ValidatedBuildIDs = []string{}
+	for _, side := range []BuildComparisonSide{comparison.Base, comparison.Head} {
+		if side.validated() {
+			comparison.ValidatedBuildIDs = append(comparison.ValidatedBuildIDs, side.BuildID)
+		}
+	}
+	return comparison, nil
+}
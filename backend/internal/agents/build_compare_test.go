@@ -0,0 +1,131 @@
+package agents
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/pkg/models"
+)
+
+func comparisonSnapshot(t *testing.T, buildID, description, powerMode string, cost float64, files []GeneratedFile, state BuildSnapshotState) *models.CompletedBuild {
+	t.Helper()
+	filesJSON, err := json.Marshal(files)
+	if err != nil {
+		t.Fatalf("marshal files: %v", err)
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("marshal state: %v", err)
+	}
+	return &models.CompletedBuild{
+		BuildID:     buildID,
+		UserID:      1,
+		Description: description,
+		Status:      string(BuildCompleted),
+		Mode:        "full",
+		PowerMode:   powerMode,
+		FilesJSON:   string(filesJSON),
+		StateJSON:   string(stateJSON),
+		FilesCount:  len(files),
+		TotalCost:   cost,
+		DurationMs:  int64(cost * 1000),
+	}
+}
+
+func TestCompareBuildSnapshotsDiffsFilesCostAndValidation(t *testing.T) {
+	base := comparisonSnapshot(t, "fast-build", "A todo app", "fast", 0.4, []GeneratedFile{
+		{Path: "src/App.tsx", Content: "line one\nline two\n"},
+		{Path: "src/old.ts", Content: "export const old = 1\n"},
+		{Path: "package.json", Content: "{}\n"},
+	}, BuildSnapshotState{QualityGateStatus: "failed", AgentTelemetry: map[string]AgentTelemetrySummary{"a": {Calls: 2, InputTokens: 100, OutputTokens: 50}}})
+	head := comparisonSnapshot(t, "max-build", "  a TODO   app ", "max", 1.5, []GeneratedFile{
+		{Path: "src/App.tsx", Content: "line one\nline 2\n"},
+		{Path: "src/new.ts", Content: "export const added = 1\n"},
+		{Path: "package.json", Content: "{}\n"},
+	}, BuildSnapshotState{QualityGateStatus: "passed", AgentTelemetry: map[string]AgentTelemetrySummary{"a": {Calls: 5, InputTokens: 900, OutputTokens: 400}}})
+
+	comparison, err := CompareBuildSnapshots(base, head)
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if comparison.MatchedBy != "description" {
+		t.Fatalf("expected a description match, got %q", comparison.MatchedBy)
+	}
+	summary := comparison.Summary
+	if summary.Added != 1 || summary.Removed != 1 || summary.Changed != 1 || summary.Unchanged != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if len(comparison.Files) != 3 {
+		t.Fatalf("expected three differing files, got %+v", comparison.Files)
+	}
+	changed := comparison.Files[0]
+	if changed.Path != "src/App.tsx" || changed.Status != "changed" || changed.LinesAdded != 1 || changed.LinesRemoved != 1 {
+		t.Fatalf("unexpected changed file %+v", changed)
+	}
+	if !strings.Contains(changed.Diff, "--- a/src/App.tsx") || !strings.Contains(changed.Diff, "+line 2") {
+		t.Fatalf("expected a unified diff, got %q", changed.Diff)
+	}
+	if added := comparison.Files[1]; added.Status != "added" || !strings.Contains(added.Diff, "--- /dev/null") {
+		t.Fatalf("unexpected added file %+v", added)
+	}
+
+	if comparison.Cost.CheaperBuildID != "fast-build" || comparison.Cost.InputTokensDelta != 800 || comparison.Head.AICalls != 5 {
+		t.Fatalf("unexpected cost comparison %+v / head %+v", comparison.Cost, comparison.Head)
+	}
+	if len(comparison.ValidatedBuildIDs) != 1 || comparison.ValidatedBuildIDs[0] != "max-build" {
+		t.Fatalf("expected only the max build to count as validated, got %v", comparison.ValidatedBuildIDs)
+	}
+}
+
+func TestCompareBuildsRejectsUnrelatedBuilds(t *testing.T) {
+	db := openBuildTestDB(t)
+	for _, snapshot := range []*models.CompletedBuild{
+		comparisonSnapshot(t, "build-a", "A todo app", "fast", 0.2, nil, BuildSnapshotState{}),
+		comparisonSnapshot(t, "build-b", "A todo app", "max", 0.9, []GeneratedFile{{Path: "src/App.tsx", Content: "x\n"}}, BuildSnapshotState{}),
+		comparisonSnapshot(t, "build-c", "A chat app", "max", 0.9, nil, BuildSnapshotState{}),
+	} {
+		if err := db.Create(snapshot).Error; err != nil {
+			t.Fatalf("create snapshot: %v", err)
+		}
+	}
+	am := &AgentManager{
+		db:          db,
+		builds:      make(map[string]*Build),
+		agents:      make(map[string]*Agent),
+		subscribers: make(map[string][]chan *WSMessage),
+	}
+	router := testRouter(am)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/builds/build-a/compare/build-b", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Comparison BuildComparison `json:"comparison"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Comparison.Summary.Added != 1 || resp.Comparison.Head.BuildID != "build-b" {
+		t.Fatalf("unexpected comparison %+v", resp.Comparison)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/builds/build-a/compare/build-c", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unrelated builds, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/builds/build-a/compare/missing", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing build, got %d", w.Code)
+	}
+}
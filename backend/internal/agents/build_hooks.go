@@ -0,0 +1,91 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"apex-build/internal/buildhooks"
+)
+
+// BuildHookRunner runs the hooks a project declares in apex.yaml.
+// Implemented by *buildhooks.Runner; wired via SetBuildHooks.
+type BuildHookRunner interface {
+	Run(ctx context.Context, stage buildhooks.Stage, req buildhooks.Request, emit func(buildhooks.Event)) (*buildhooks.StageResult, error)
+}
+
+// SetBuildHooks makes builds run the project's pre_build and post_build
+// hooks.
+func (am *AgentManager) SetBuildHooks(runner BuildHookRunner) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.buildHooks = runner
+}
+
+// runBuildHooks runs stage for build against the build's project files with
+// files laid over them, broadcasting each hook's progress on the build
+// WebSocket. It returns the reason the build must fail, or "" when nothing
+// blocked. pre_build only applies to builds on an existing project, since a
+// new build has no apex.yaml yet.
+func (am *AgentManager) runBuildHooks(build *Build, stage buildhooks.Stage, files []GeneratedFile) string {
+	if am == nil || build == nil {
+		return ""
+	}
+	am.mu.RLock()
+	runner := am.buildHooks
+	ctx := am.ctx
+	am.mu.RUnlock()
+	if runner == nil {
+		return ""
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	build.mu.RLock()
+	buildID := build.ID
+	req := buildhooks.Request{UserID: build.UserID}
+	if build.ProjectID != nil {
+		req.ProjectID = *build.ProjectID
+	}
+	build.mu.RUnlock()
+	if req.ProjectID == 0 && len(files) == 0 {
+		return ""
+	}
+	if len(files) > 0 {
+		req.Files = make(map[string]string, len(files))
+		for _, f := range files {
+			req.Files[f.Path] = f.Content
+		}
+	}
+
+	result, err := runner.Run(ctx, stage, req, func(ev buildhooks.Event) {
+		msgType := WSBuildHookStarted
+		data := map[string]any{"stage": string(ev.Stage), "hook": ev.Hook, "index": ev.Index}
+		switch ev.Type {
+		case buildhooks.EventOutput:
+			msgType = WSBuildHookOutput
+			data["output"] = ev.Output
+		case buildhooks.EventFinished:
+			msgType = WSBuildHookFinished
+			data["command"] = ev.Command
+			data["result"] = ev.Result
+		default:
+			data["command"] = ev.Command
+		}
+		am.broadcast(buildID, &WSMessage{Type: msgType, BuildID: buildID, Timestamp: time.Now(), Data: data})
+	})
+	if err != nil {
+		if errors.Is(err, buildhooks.ErrInvalidConfig) {
+			return err.Error()
+		}
+		log.Printf("Build %s: %s hooks skipped: %v", buildID, stage, err)
+		return ""
+	}
+	if result == nil {
+		return ""
+	}
+	log.Printf("Build %s: ran %d %s hook(s), blocked=%v", buildID, len(result.Hooks), stage, result.Blocked)
+	return result.BlockError()
+}
@@ -0,0 +1,85 @@
+package agents
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"apex-build/internal/buildfailures"
+)
+
+// BuildOutcomeRecorder stores the classified outcome of a finished build.
+// Implemented by *buildfailures.Service; wired via SetBuildOutcomeRecorder.
+type BuildOutcomeRecorder interface {
+	Record(ctx context.Context, outcome *buildfailures.Outcome, signals buildfailures.Signals) error
+}
+
+// SetBuildOutcomeRecorder makes terminal builds record their failure reason
+// for the failure-cause and flaky-build reports.
+func (am *AgentManager) SetBuildOutcomeRecorder(recorder BuildOutcomeRecorder) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.outcomeRecorder = recorder
+}
+
+// recordBuildOutcome classifies a terminal build with the same signals the
+// pipeline uses for repair decisions: the failure taxonomy, the coarse error
+// class and, for final validation failures, the readiness error class.
+func (am *AgentManager) recordBuildOutcome(build *Build) {
+	if am == nil || build == nil {
+		return
+	}
+	am.mu.RLock()
+	recorder := am.outcomeRecorder
+	am.mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+
+	build.mu.RLock()
+	status := string(build.Status)
+	switch build.Status {
+	case BuildCompleted, BuildFailed, BuildCancelled:
+	default:
+		build.mu.RUnlock()
+		return
+	}
+	finishedAt := time.Now().UTC()
+	if build.CompletedAt != nil {
+		finishedAt = build.CompletedAt.UTC()
+	}
+	outcome := &buildfailures.Outcome{
+		BuildID:     build.ID,
+		UserID:      build.UserID,
+		ProjectID:   build.ProjectID,
+		Description: strings.TrimSpace(build.Description),
+		Mode:        string(build.Mode),
+		PowerMode:   string(build.PowerMode),
+		DurationMs:  finishedAt.Sub(build.CreatedAt).Milliseconds(),
+		FinishedAt:  finishedAt,
+	}
+	signals := buildfailures.Signals{Status: status, Error: strings.TrimSpace(build.Error)}
+	if ft := build.SnapshotState.FailureTaxonomy; ft != nil {
+		signals.FailureCategory = string(ft.CurrentCategory)
+		if signals.FailureCategory == "" {
+			signals.FailureCategory = string(ft.LastCategory)
+		}
+		signals.FailureClass = strings.TrimSpace(ft.CurrentClass)
+		if signals.FailureClass == "" {
+			signals.FailureClass = strings.TrimSpace(ft.LastClass)
+		}
+	}
+	build.mu.RUnlock()
+
+	if status == string(BuildFailed) {
+		signals.ErrorClass = normalizeFailureClass(signals.Error)
+		signals.ReadinessClass = readinessErrorClassFromBuildError(signals.Error)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := recorder.Record(ctx, outcome, signals); err != nil {
+		log.Printf("Failed to record outcome for build %s: %v", outcome.BuildID, err)
+	}
+}
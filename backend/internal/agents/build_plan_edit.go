@@ -0,0 +1,331 @@
+// build_plan_edit.go — Structured view and edits of the lead agent's plan.
+//
+// While a build waits at the plan approval gate, its plan can be read as
+// structured data (stack, features, files, endpoints) and edited in place:
+// features, files and endpoints can be removed, files renamed and the stack
+// changed. Edits are applied to build.Plan before the manager compiles the
+// contract and queues phase tasks, so every agent works from the edited plan.
+package agents
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BuildPlanOutline is the editable, structured view of a build plan
+type BuildPlanOutline struct {
+	PlanID            string        `json:"plan_id"`
+	AppType           string        `json:"app_type"`
+	TechStack         TechStack     `json:"tech_stack"`
+	Features          []Feature     `json:"features"`
+	Files             []PlannedFile `json:"files"`
+	APIEndpoints      []APIEndpoint `json:"api_endpoints"`
+	DataModels        []DataModel   `json:"data_models"`
+	Components        []UIComponent `json:"components"`
+	Editable          bool          `json:"editable"`
+	ApprovalRequestID string        `json:"approval_request_id,omitempty"`
+}
+
+// BuildPlanEdit describes changes to a plan waiting for approval. Features
+// are matched by ID or name, endpoints as "METHOD /path", files by path.
+type BuildPlanEdit struct {
+	TechStack       *TechStack        `json:"tech_stack,omitempty"` // non-empty fields replace the planned stack
+	RemoveFeatures  []string          `json:"remove_features,omitempty"`
+	RemoveFiles     []string          `json:"remove_files,omitempty"`
+	RenameFiles     map[string]string `json:"rename_files,omitempty"` // old path → new path
+	RemoveEndpoints []string          `json:"remove_endpoints,omitempty"`
+}
+
+func (e BuildPlanEdit) empty() bool {
+	return e.TechStack == nil && len(e.RemoveFeatures) == 0 && len(e.RemoveFiles) == 0 &&
+		len(e.RenameFiles) == 0 && len(e.RemoveEndpoints) == 0
+}
+
+func buildPlanOutlineLocked(build *Build) *BuildPlanOutline {
+	if build == nil || build.Plan == nil {
+		return nil
+	}
+	plan := cloneBuildPlan(build.Plan)
+	outline := &BuildPlanOutline{
+		PlanID:       plan.ID,
+		AppType:      plan.AppType,
+		TechStack:    plan.TechStack,
+		Features:     plan.Features,
+		Files:        plan.Files,
+		APIEndpoints: plan.APIEndpoints,
+		DataModels:   plan.DataModels,
+		Components:   plan.Components,
+	}
+	if idx := pendingPlanApprovalIndexLocked(build); idx >= 0 {
+		outline.Editable = true
+		outline.ApprovalRequestID = build.Interaction.ApprovalRequests[idx].ID
+	}
+	return outline
+}
+
+func pendingPlanApprovalIndexLocked(build *Build) int {
+	idx := latestApprovalRequestIndexLocked(build, ApprovalCheckpointPlan)
+	if idx < 0 || build.Interaction.ApprovalRequests[idx].Status != ApprovalRequestPending {
+		return -1
+	}
+	return idx
+}
+
+func planEndpointKey(endpoint APIEndpoint) string {
+	return strings.ToUpper(strings.TrimSpace(endpoint.Method)) + " " + strings.TrimSpace(endpoint.Path)
+}
+
+func normalizePlanEndpointKey(raw string) string {
+	fields := strings.Fields(raw)
+	if len(fields) != 2 {
+		return strings.TrimSpace(raw)
+	}
+	return strings.ToUpper(fields[0]) + " " + fields[1]
+}
+
+// applyBuildPlanEdit applies edit to plan in place. Unknown features, files or
+// endpoints are reported rather than ignored so the caller can tell a typo
+// from a no-op.
+func applyBuildPlanEdit(plan *BuildPlan, edit BuildPlanEdit) error {
+	if plan == nil {
+		return fmt.Errorf("build has no plan to edit")
+	}
+	if edit.empty() {
+		return fmt.Errorf("plan edit is empty")
+	}
+
+	if len(edit.RemoveFeatures) > 0 {
+		removed := make(map[string]bool, len(edit.RemoveFeatures))
+		for _, ref := range edit.RemoveFeatures {
+			ref = strings.TrimSpace(ref)
+			found := false
+			for _, feature := range plan.Features {
+				if feature.ID == ref || strings.EqualFold(feature.Name, ref) {
+					removed[feature.ID] = true
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("feature %q is not in the plan", ref)
+			}
+		}
+		kept := plan.Features[:0]
+		for _, feature := range plan.Features {
+			if removed[feature.ID] {
+				continue
+			}
+			feature.Dependencies = filterSlice(feature.Dependencies, func(dep string) bool { return !removed[dep] })
+			kept = append(kept, feature)
+		}
+		plan.Features = kept
+	}
+
+	if len(edit.RemoveEndpoints) > 0 {
+		removed := make(map[string]bool, len(edit.RemoveEndpoints))
+		for _, ref := range edit.RemoveEndpoints {
+			key := normalizePlanEndpointKey(ref)
+			found := false
+			for _, endpoint := range plan.APIEndpoints {
+				if planEndpointKey(endpoint) == key {
+					found = true
+				}
+			}
+			if plan.APIContract != nil {
+				for _, endpoint := range plan.APIContract.Endpoints {
+					if planEndpointKey(endpoint) == key {
+						found = true
+					}
+				}
+			}
+			if !found {
+				return fmt.Errorf("endpoint %q is not in the plan", ref)
+			}
+			removed[key] = true
+		}
+		keep := func(endpoint APIEndpoint) bool { return !removed[planEndpointKey(endpoint)] }
+		plan.APIEndpoints = filterSlice(plan.APIEndpoints, keep)
+		if plan.APIContract != nil {
+			plan.APIContract.Endpoints = filterSlice(plan.APIContract.Endpoints, keep)
+		}
+	}
+
+	if len(edit.RemoveFiles) > 0 {
+		removed := make(map[string]bool, len(edit.RemoveFiles))
+		for _, ref := range edit.RemoveFiles {
+			path := normalizeOwnedPath(ref)
+			if planFileIndex(plan, path) < 0 {
+				return fmt.Errorf("file %q is not in the plan", ref)
+			}
+			removed[path] = true
+		}
+		keep := func(path string) bool { return !removed[normalizeOwnedPath(path)] }
+		plan.Files = filterSlice(plan.Files, func(file PlannedFile) bool { return keep(file.Path) })
+		plan.Ownership = filterSlice(plan.Ownership, func(owned BuildOwnership) bool { return keep(owned.Path) })
+		rewritePlanFileReferences(plan, func(path string) (string, bool) { return path, keep(path) })
+	}
+
+	if len(edit.RenameFiles) > 0 {
+		renames := make(map[string]string, len(edit.RenameFiles))
+		for from, to := range edit.RenameFiles {
+			from, to = normalizeOwnedPath(from), normalizeOwnedPath(to)
+			if from == "" || to == "" {
+				return fmt.Errorf("file renames need both an old and a new path")
+			}
+			if planFileIndex(plan, from) < 0 {
+				return fmt.Errorf("file %q is not in the plan", from)
+			}
+			if from != to && planFileIndex(plan, to) >= 0 {
+				return fmt.Errorf("file %q is already in the plan", to)
+			}
+			renames[from] = to
+		}
+		rename := func(path string) (string, bool) {
+			if to, ok := renames[normalizeOwnedPath(path)]; ok {
+				return to, true
+			}
+			return path, true
+		}
+		for idx := range plan.Files {
+			plan.Files[idx].Path, _ = rename(plan.Files[idx].Path)
+		}
+		for idx := range plan.Ownership {
+			plan.Ownership[idx].Path, _ = rename(plan.Ownership[idx].Path)
+		}
+		for idx := range plan.ScaffoldFiles {
+			plan.ScaffoldFiles[idx].Path, _ = rename(plan.ScaffoldFiles[idx].Path)
+		}
+		rewritePlanFileReferences(plan, rename)
+	}
+
+	if edit.TechStack != nil {
+		if stack := strings.TrimSpace(edit.TechStack.Frontend); stack != "" {
+			plan.TechStack.Frontend = stack
+		}
+		if stack := strings.TrimSpace(edit.TechStack.Backend); stack != "" {
+			plan.TechStack.Backend = stack
+		}
+		if stack := strings.TrimSpace(edit.TechStack.Database); stack != "" {
+			plan.TechStack.Database = stack
+		}
+		if stack := strings.TrimSpace(edit.TechStack.Styling); stack != "" {
+			plan.TechStack.Styling = stack
+		}
+		if edit.TechStack.Extras != nil {
+			plan.TechStack.Extras = append([]string(nil), edit.TechStack.Extras...)
+		}
+	}
+	return nil
+}
+
+func planFileIndex(plan *BuildPlan, path string) int {
+	for idx, file := range plan.Files {
+		if normalizeOwnedPath(file.Path) == path {
+			return idx
+		}
+	}
+	return -1
+}
+
+// rewritePlanFileReferences maps every file reference outside plan.Files
+// (file dependencies and work order file lists); rewrite returns false to
+// drop the reference.
+func rewritePlanFileReferences(plan *BuildPlan, rewrite func(string) (string, bool)) {
+	rewriteAll := func(paths []string) []string {
+		if paths == nil {
+			return nil
+		}
+		out := make([]string, 0, len(paths))
+		for _, path := range paths {
+			if next, keep := rewrite(path); keep {
+				out = append(out, next)
+			}
+		}
+		return out
+	}
+	for idx := range plan.Files {
+		plan.Files[idx].Dependencies = rewriteAll(plan.Files[idx].Dependencies)
+	}
+	for idx := range plan.WorkOrders {
+		order := &plan.WorkOrders[idx]
+		order.OwnedFiles = rewriteAll(order.OwnedFiles)
+		order.RequiredFiles = rewriteAll(order.RequiredFiles)
+		order.ForbiddenFiles = rewriteAll(order.ForbiddenFiles)
+	}
+}
+
+func filterSlice[T any](items []T, keep func(T) bool) []T {
+	if items == nil {
+		return nil
+	}
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// GetBuildPlanOutline returns the build's plan as structured data. The
+// outline is editable while the plan waits at the plan approval gate.
+func (am *AgentManager) GetBuildPlanOutline(buildID string) (*BuildPlanOutline, error) {
+	build, err := am.GetBuild(buildID)
+	if err != nil {
+		return nil, err
+	}
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+	outline := buildPlanOutlineLocked(build)
+	if outline == nil {
+		return nil, fmt.Errorf("build %s has no plan yet", buildID)
+	}
+	return outline, nil
+}
+
+// EditBuildPlan applies a structured edit to a plan waiting at the plan
+// approval gate and broadcasts the updated outline.
+func (am *AgentManager) EditBuildPlan(buildID string, edit BuildPlanEdit) (*BuildPlanOutline, error) {
+	build, err := am.GetBuild(buildID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	build.mu.Lock()
+	idx := pendingPlanApprovalIndexLocked(build)
+	if idx < 0 {
+		build.mu.Unlock()
+		return nil, fmt.Errorf("build %s has no plan waiting for approval; start the build with a plan approval gate to edit its plan", buildID)
+	}
+	// Edit a copy so a rejected edit leaves the plan untouched.
+	edited := cloneBuildPlan(build.Plan)
+	if err := applyBuildPlanEdit(edited, edit); err != nil {
+		build.mu.Unlock()
+		return nil, err
+	}
+	build.Plan = edited
+	build.Interaction.ApprovalRequests[idx].PlanEdited = true
+	build.Interaction.ApprovalRequests[idx].Summary = planApprovalSummary(edited)
+	appendBuildConversationMessageLocked(build, BuildConversationMessage{
+		Role:      ConversationRoleSystem,
+		Kind:      ConversationKindDirective,
+		Content:   "Build plan edited by user",
+		Timestamp: now,
+	})
+	build.UpdatedAt = now
+	refreshDerivedSnapshotStateLocked(build, &build.SnapshotState)
+	outline := buildPlanOutlineLocked(build)
+	build.mu.Unlock()
+
+	am.persistBuildSnapshot(build, nil)
+	am.broadcast(buildID, &WSMessage{
+		Type:      WSBuildPlanUpdated,
+		BuildID:   buildID,
+		Timestamp: now,
+		Data: map[string]any{
+			"plan": outline,
+		},
+	})
+	return outline, nil
+}
@@ -0,0 +1,116 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+)
+
+func editablePlanFixture() *BuildPlan {
+	return &BuildPlan{
+		ID:        "plan-1",
+		TechStack: TechStack{Frontend: "React", Backend: "Express", Database: "PostgreSQL"},
+		Features: []Feature{
+			{ID: "f-auth", Name: "Authentication"},
+			{ID: "f-billing", Name: "Billing", Dependencies: []string{"f-auth"}},
+			{ID: "f-dash", Name: "Dashboard", Dependencies: []string{"f-billing"}},
+		},
+		Files: []PlannedFile{
+			{Path: "src/App.tsx", Type: "frontend", Dependencies: []string{"src/api.ts"}},
+			{Path: "src/api.ts", Type: "frontend"},
+			{Path: "server/billing.ts", Type: "backend"},
+		},
+		Ownership:  []BuildOwnership{{Path: "src/api.ts", Role: RoleFrontend}, {Path: "server/billing.ts", Role: RoleBackend}},
+		WorkOrders: []BuildWorkOrder{{Role: RoleFrontend, OwnedFiles: []string{"src/App.tsx", "src/api.ts"}, RequiredFiles: []string{"server/billing.ts"}}},
+		APIEndpoints: []APIEndpoint{
+			{Method: "GET", Path: "/api/invoices"},
+			{Method: "POST", Path: "/api/login"},
+		},
+		APIContract: &BuildAPIContract{Endpoints: []APIEndpoint{{Method: "GET", Path: "/api/invoices"}, {Method: "POST", Path: "/api/login"}}},
+	}
+}
+
+func TestApplyBuildPlanEditRemovesRenamesAndRestacks(t *testing.T) {
+	plan := editablePlanFixture()
+	err := applyBuildPlanEdit(plan, BuildPlanEdit{
+		TechStack:       &TechStack{Backend: "Go"},
+		RemoveFeatures:  []string{"billing"},
+		RemoveFiles:     []string{"/server/billing.ts"},
+		RenameFiles:     map[string]string{"src/api.ts": "src/lib/client.ts"},
+		RemoveEndpoints: []string{"get /api/invoices"},
+	})
+	if err != nil {
+		t.Fatalf("apply edit: %v", err)
+	}
+
+	if plan.TechStack.Backend != "Go" || plan.TechStack.Frontend != "React" {
+		t.Fatalf("unexpected stack %+v", plan.TechStack)
+	}
+	if len(plan.Features) != 2 || len(plan.Features[1].Dependencies) != 0 {
+		t.Fatalf("expected billing and references to it removed, got %+v", plan.Features)
+	}
+	if len(plan.Files) != 2 || plan.Files[1].Path != "src/lib/client.ts" || plan.Files[0].Dependencies[0] != "src/lib/client.ts" {
+		t.Fatalf("unexpected files %+v", plan.Files)
+	}
+	if len(plan.Ownership) != 1 || plan.Ownership[0].Path != "src/lib/client.ts" {
+		t.Fatalf("unexpected ownership %+v", plan.Ownership)
+	}
+	order := plan.WorkOrders[0]
+	if strings.Join(order.OwnedFiles, ",") != "src/App.tsx,src/lib/client.ts" || len(order.RequiredFiles) != 0 {
+		t.Fatalf("unexpected work order files %+v", order)
+	}
+	if len(plan.APIEndpoints) != 1 || len(plan.APIContract.Endpoints) != 1 || plan.APIContract.Endpoints[0].Path != "/api/login" {
+		t.Fatalf("expected the invoices endpoint removed from plan and contract, got %+v / %+v", plan.APIEndpoints, plan.APIContract.Endpoints)
+	}
+}
+
+func TestApplyBuildPlanEditRejectsUnknownReferences(t *testing.T) {
+	cases := map[string]BuildPlanEdit{
+		"empty":            {},
+		"unknown feature":  {RemoveFeatures: []string{"chat"}},
+		"unknown file":     {RemoveFiles: []string{"src/missing.ts"}},
+		"rename collision": {RenameFiles: map[string]string{"src/App.tsx": "src/api.ts"}},
+		"unknown endpoint": {RemoveEndpoints: []string{"DELETE /api/invoices"}},
+	}
+	for name, edit := range cases {
+		if err := applyBuildPlanEdit(editablePlanFixture(), edit); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEditBuildPlanRequiresPendingPlanApproval(t *testing.T) {
+	am, build, ch := newApprovalGateTestManager(BuildApprovalGate{Checkpoint: ApprovalCheckpointPlan, TimeoutSeconds: 600, OnTimeout: ApprovalTimeoutReject})
+	build.Plan = editablePlanFixture()
+
+	edit := BuildPlanEdit{RemoveFeatures: []string{"f-dash"}}
+	if _, err := am.EditBuildPlan(build.ID, edit); err == nil {
+		t.Fatal("expected edits to be refused before the plan gate opens")
+	}
+
+	if !am.holdAtApprovalGate(build, ApprovalCheckpointPlan, "Plan ready", nil) {
+		t.Fatal("expected the plan gate to hold the build")
+	}
+	if msg := waitForWSMessage(t, ch, WSBuildApprovalRequest); msg.Data.(map[string]any)["plan"] == nil {
+		t.Fatal("expected the approval request to carry the structured plan")
+	}
+
+	outline, err := am.EditBuildPlan(build.ID, edit)
+	if err != nil {
+		t.Fatalf("edit plan: %v", err)
+	}
+	if !outline.Editable || len(outline.Features) != 2 || outline.ApprovalRequestID == "" {
+		t.Fatalf("unexpected outline %+v", outline)
+	}
+	waitForWSMessage(t, ch, WSBuildPlanUpdated)
+
+	// A failed edit leaves the plan as it was.
+	if _, err := am.EditBuildPlan(build.ID, BuildPlanEdit{RemoveFeatures: []string{"f-auth", "missing"}}); err == nil {
+		t.Fatal("expected an edit with an unknown feature to fail")
+	}
+	build.mu.RLock()
+	features, edited := len(build.Plan.Features), build.Interaction.ApprovalRequests[0].PlanEdited
+	build.mu.RUnlock()
+	if features != 2 || !edited {
+		t.Fatalf("expected the first edit to stick and be recorded, got %d features (edited=%v)", features, edited)
+	}
+}
@@ -88,6 +88,7 @@ func snapshotStateForPersistenceLocked(build *Build) BuildSnapshotState {
 		MaxRetries:                  build.MaxRetries,
 		MaxRequests:                 build.MaxRequests,
 		MaxTokensPerRequest:         build.MaxTokensPerRequest,
+		FileBudget:                  build.FileBudget,
 		PhasedPipelineComplete:      build.PhasedPipelineComplete,
 		DiffMode:                    build.DiffMode,
 		RoleAssignments:             cloneStringMap(build.RoleAssignments),
@@ -100,6 +101,11 @@ func snapshotStateForPersistenceLocked(build *Build) BuildSnapshotState {
 		MobileReleaseLevel:          build.MobileReleaseLevel,
 		MobileCapabilities:          effectiveMobileCapabilities(build.MobileCapabilities, nil),
 		MobileAppSpec:               build.MobileAppSpec,
+		I18n:                        cloneBuildI18nOptions(build.I18n),
+		ApprovalGates:               append([]BuildApprovalGate(nil), build.ApprovalGates...),
+		AgentProfiles:               append([]AgentProfile(nil), build.AgentProfiles...),
+		CodingStandards:             build.CodingStandards,
+		ModelPreferences:            cloneBYOKModelPreferences(build.ModelPreferences),
 	}
 	return state
 }
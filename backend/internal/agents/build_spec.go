@@ -2319,8 +2319,10 @@ func selectBuildScaffold(appType string, stack TechStack) buildScaffold {
 			{Path: "README.md", Type: "docs", Description: "Run instructions and project overview"},
 			{Path: "app/layout.tsx", Type: "frontend", Description: "Root layout component"},
 			{Path: "app/page.tsx", Type: "frontend", Description: "Home page"},
+			{Path: "app/globals.css", Type: "frontend", Description: "Global styles with Tailwind directives"},
 			{Path: "next.config.js", Type: "config", Description: "Next.js configuration"},
 			{Path: "package.json", Type: "config", Description: "Dependency manifest"},
+			{Path: "postcss.config.js", Type: "config", Description: "PostCSS config for Tailwind"},
 			{Path: "tailwind.config.js", Type: "config", Description: "Tailwind configuration"},
 			{Path: "tsconfig.json", Type: "config", Description: "TypeScript config"},
 		}
@@ -3297,6 +3299,12 @@ if __name__ == "__main__":
 		add("next.config.js", `/** @type {import('next').NextConfig} */
 const nextConfig = {};
 module.exports = nextConfig;`)
+		add("postcss.config.js", `module.exports = {
+  plugins: {
+    tailwindcss: {},
+    autoprefixer: {},
+  },
+};`)
 		add("tailwind.config.js", `/** @type {import('tailwindcss').Config} */
 module.exports = {
   content: ["./app/**/*.{js,ts,jsx,tsx}", "./components/**/*.{js,ts,jsx,tsx}"],
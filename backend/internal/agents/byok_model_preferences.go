@@ -0,0 +1,43 @@
+package agents
+
+import (
+	"strings"
+
+	"apex-build/internal/ai"
+)
+
+// resolveBuildBYOKModelPreferences loads the user's per-capability BYOK model
+// preferences for a new build. Platform-key builds ignore them.
+func (am *AgentManager) resolveBuildBYOKModelPreferences(userID uint) ai.ModelPreferences {
+	if am == nil || am.db == nil || userID == 0 {
+		return nil
+	}
+	return ai.LoadCapabilityModelPreferences(am.db, userID)
+}
+
+func cloneBYOKModelPreferences(prefs ai.ModelPreferences) ai.ModelPreferences {
+	if len(prefs) == 0 {
+		return nil
+	}
+	cloned := make(ai.ModelPreferences, len(prefs))
+	for provider, scopes := range prefs {
+		cloned[provider] = cloneStringMap(scopes)
+	}
+	return cloned
+}
+
+// byokModelPreferenceForRole returns the BYOK model the user chose for an agent
+// role on provider, or "" when none applies. A per-build provider model
+// override still wins over it.
+func byokModelPreferenceForRole(build *Build, provider ai.AIProvider, role AgentRole) string {
+	if build == nil {
+		return ""
+	}
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+	if strings.ToLower(strings.TrimSpace(build.ProviderMode)) != "byok" {
+		return ""
+	}
+	model := ai.CapabilityModel(build.ModelPreferences[string(provider)], ai.BuildRoleModelScope(string(role)))
+	return normalizeProviderModelOverride(provider, model)
+}
@@ -0,0 +1,36 @@
+package agents
+
+import (
+	"testing"
+
+	"apex-build/internal/ai"
+)
+
+func TestBYOKModelPreferenceForRoleAppliesOnlyToBYOKBuilds(t *testing.T) {
+	prefs := ai.ModelPreferences{
+		"claude": {"build": "claude-sonnet-4-6", "build:reviewer": "claude-opus-4-7"},
+		"gpt4":   {"completions": "gpt-4o-mini"},
+	}
+	build := &Build{ProviderMode: "byok", ModelPreferences: prefs}
+
+	if got := byokModelPreferenceForRole(build, ai.ProviderClaude, RoleReviewer); got != "claude-opus-4-7" {
+		t.Fatalf("reviewer model = %q, want claude-opus-4-7", got)
+	}
+	if got := byokModelPreferenceForRole(build, ai.ProviderClaude, RoleFrontend); got != "claude-sonnet-4-6" {
+		t.Fatalf("frontend should fall back to the build model, got %q", got)
+	}
+	if got := byokModelPreferenceForRole(build, ai.ProviderGPT4, RoleFrontend); got != "" {
+		t.Fatalf("completions-only preferences must not apply to builds, got %q", got)
+	}
+
+	platform := &Build{ProviderMode: "platform", ModelPreferences: prefs}
+	if got := byokModelPreferenceForRole(platform, ai.ProviderClaude, RoleReviewer); got != "" {
+		t.Fatalf("platform builds must ignore BYOK preferences, got %q", got)
+	}
+
+	restored := cloneBYOKModelPreferences(prefs)
+	restored["claude"]["build"] = "changed"
+	if prefs["claude"]["build"] != "claude-sonnet-4-6" {
+		t.Fatal("clone must not share scope maps")
+	}
+}
@@ -0,0 +1,104 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"apex-build/internal/codestandards"
+)
+
+// CodingStandardsSource returns the merged coding standards of the
+// organizations a user belongs to. Implemented by codestandards.Service;
+// wired via SetCodingStandardsSource in main.go.
+type CodingStandardsSource interface {
+	ForUser(ctx context.Context, userID uint) (*codestandards.Policy, error)
+}
+
+// SetCodingStandardsSource wires organization coding standards into builds.
+func (am *AgentManager) SetCodingStandardsSource(source CodingStandardsSource) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.codingStandards = source
+}
+
+// resolveBuildCodingStandards loads the standards that apply to a new build.
+// The policy is fixed for the life of the build so prompts and the review
+// check agree even if an admin edits the standards mid-build.
+func (am *AgentManager) resolveBuildCodingStandards(userID uint) *codestandards.Policy {
+	// Wired once at startup; read without am.mu like the other prompt sources.
+	source := am.codingStandards
+	if source == nil || userID == 0 {
+		return nil
+	}
+	ctx := am.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	policy, err := source.ForUser(ctx, userID)
+	if err != nil {
+		log.Printf("Could not load coding standards for user %d: %v", userID, err)
+		return nil
+	}
+	return policy
+}
+
+// codingStandardsPromptContext returns the standards block for agent prompts.
+func codingStandardsPromptContext(build *Build) string {
+	if build == nil {
+		return ""
+	}
+	return codestandards.PromptContext(build.CodingStandards)
+}
+
+// runCodingStandardsCheck checks the generated files against the build's
+// standards, records the report on the build snapshot and broadcasts it.
+// It runs when review completes and returns nil if no standards apply.
+func (am *AgentManager) runCodingStandardsCheck(build *Build) *codestandards.Report {
+	if build == nil || build.CodingStandards.Empty() {
+		return nil
+	}
+	generated := am.collectGeneratedFiles(build)
+	files := make([]codestandards.File, 0, len(generated))
+	for _, file := range generated {
+		files = append(files, codestandards.File{Path: file.Path, Content: file.Content})
+	}
+	report := codestandards.Check(build.CodingStandards, files)
+
+	build.mu.Lock()
+	build.SnapshotState.CodingStandards = report
+	build.mu.Unlock()
+
+	message := fmt.Sprintf("Coding standards check passed (%d files).", len(files))
+	if report.Errors > 0 || report.Warnings > 0 {
+		message = fmt.Sprintf("Coding standards check found %d error(s) and %d warning(s).", report.Errors, report.Warnings)
+	}
+	am.broadcast(build.ID, &WSMessage{
+		Type:      WSBuildCodingStandards,
+		BuildID:   build.ID,
+		Timestamp: time.Now(),
+		Data: map[string]any{
+			"passed":     report.Passed(),
+			"errors":     report.Errors,
+			"warnings":   report.Warnings,
+			"truncated":  report.Truncated,
+			"violations": report.Violations,
+			"sources":    build.CodingStandards.Sources,
+		},
+	})
+	am.broadcast(build.ID, &WSMessage{
+		Type:      WSBuildProgress,
+		BuildID:   build.ID,
+		Timestamp: time.Now(),
+		Data: map[string]any{
+			"message":            message,
+			"phase":              "reviewing",
+			"status":             string(BuildReviewing),
+			"quality_gate_stage": "review",
+		},
+	})
+	return report
+}
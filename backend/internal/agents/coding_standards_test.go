@@ -0,0 +1,55 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"apex-build/internal/codestandards"
+)
+
+type stubCodingStandardsSource struct {
+	policy *codestandards.Policy
+}
+
+func (s stubCodingStandardsSource) ForUser(ctx context.Context, userID uint) (*codestandards.Policy, error) {
+	return s.policy, nil
+}
+
+func TestCodingStandardsInjectedAndCheckedAtReview(t *testing.T) {
+	am := &AgentManager{builds: map[string]*Build{}}
+	am.SetCodingStandardsSource(stubCodingStandardsSource{policy: &codestandards.Policy{
+		StyleGuide:        "Use named exports.",
+		BannedLibraries:   []string{"axios"},
+		ForbiddenPatterns: []codestandards.PatternRule{{Name: "no console", Pattern: `console\.log`, Files: "*.ts", Severity: codestandards.SeverityWarning}},
+		Sources:           []string{"Acme"},
+	}})
+
+	build := &Build{ID: "standards-build", UserID: 3, CodingStandards: am.resolveBuildCodingStandards(3)}
+	if prompt := codingStandardsPromptContext(build); !strings.Contains(prompt, "Use named exports.") || !strings.Contains(prompt, "axios") {
+		t.Fatalf("standards should be injected into prompts, got %q", prompt)
+	}
+	if codingStandardsPromptContext(&Build{}) != "" {
+		t.Fatal("builds without standards should get no extra prompt")
+	}
+
+	build.Tasks = []*Task{{
+		ID:   "t1",
+		Type: TaskGenerateFile,
+		Output: &TaskOutput{Files: []GeneratedFile{
+			{Path: "src/api.ts", Content: "import axios from 'axios'\nconsole.log('ready')\n"},
+			{Path: "src/app.ts", Content: "export const app = 1\n"},
+		}},
+	}}
+	am.builds[build.ID] = build
+	report := am.runCodingStandardsCheck(build)
+	if report == nil || report.Errors != 1 || report.Warnings != 1 || report.Passed() {
+		t.Fatalf("expected one banned library error and one warning, got %+v", report)
+	}
+	if recorded := build.SnapshotState.CodingStandards; recorded == nil || recorded.Errors != 1 || len(recorded.Violations) != 2 {
+		t.Fatal("report should be recorded on the build snapshot")
+	}
+	if am.runCodingStandardsCheck(&Build{ID: "plain"}) != nil {
+		t.Fatal("builds without standards should not be checked")
+	}
+}
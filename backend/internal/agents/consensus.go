@@ -0,0 +1,405 @@
+package agents
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	appmiddleware "apex-build/internal/middleware"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Outcomes of a consensus round.
+const (
+	ConsensusOutcomePending      = "pending"      // the build has not shown whether the decision worked
+	ConsensusOutcomeRecovered    = "recovered"    // the task or build recovered after the decision
+	ConsensusOutcomeFailed       = "failed"       // the task or build failed anyway
+	ConsensusOutcomeInconclusive = "inconclusive" // the build was cancelled before it could tell
+)
+
+// Reasons a consensus round reached its decision.
+const (
+	ConsensusReasonWeightedMajority = "weighted_majority" // one action held more than half the vote weight
+	ConsensusReasonNoMajority       = "no_majority"       // no weighted majority; switch_provider is the safe default
+	ConsensusReasonBudget           = "budget_exceeded"   // voting stopped at the spend cap; the default strategy stands
+)
+
+const (
+	maxConsensusRounds         = 50
+	defaultConsensusCapability = 0.75
+	minConsensusVoteWeight     = 0.1
+	maxConsensusErrorChars     = 500
+)
+
+// ConsensusWeights configures how much each provider's vote counts. A vote's
+// weight is its provider's base weight, scaled up or down by how capable the
+// provider is at diagnosis and repair and by how often its past votes were
+// right. A factor of 0 turns that adjustment off.
+type ConsensusWeights struct {
+	CapabilityFactor float64
+	AccuracyFactor   float64
+	ProviderBase     map[ai.AIProvider]float64
+}
+
+// consensusWeightsFromEnv reads CONSENSUS_CAPABILITY_FACTOR,
+// CONSENSUS_ACCURACY_FACTOR (both default 1) and CONSENSUS_PROVIDER_WEIGHTS,
+// a list such as "claude:1.5,gemini:0.8" of base weights (default 1).
+func consensusWeightsFromEnv() ConsensusWeights {
+	weights := ConsensusWeights{
+		CapabilityFactor: envNonNegativeFloat("CONSENSUS_CAPABILITY_FACTOR", 1),
+		AccuracyFactor:   envNonNegativeFloat("CONSENSUS_ACCURACY_FACTOR", 1),
+		ProviderBase:     map[ai.AIProvider]float64{},
+	}
+	for _, entry := range strings.Split(os.Getenv("CONSENSUS_PROVIDER_WEIGHTS"), ",") {
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			continue
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight <= 0 {
+			continue
+		}
+		weights.ProviderBase[ai.AIProvider(strings.ToLower(strings.TrimSpace(name)))] = weight
+	}
+	return weights
+}
+
+func envNonNegativeFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// ConsensusVote is one provider's vote in a failure consensus round.
+type ConsensusVote struct {
+	Provider  ai.AIProvider     `json:"provider"`
+	Decision  consensusDecision `json:"decision"`
+	Rationale string            `json:"rationale,omitempty"`
+	// Fallback is set when the provider could not vote and was counted for
+	// the default strategy.
+	Fallback bool `json:"fallback,omitempty"`
+
+	Weight          float64 `json:"weight"`
+	Capability      float64 `json:"capability"`
+	Accuracy        float64 `json:"accuracy"`
+	AccuracySamples int     `json:"accuracy_samples"`
+}
+
+// ConsensusRound records one failure consensus: who voted for what, with
+// which weight, and what was decided, so recovery decisions can be audited.
+type ConsensusRound struct {
+	ID              string                        `json:"id"`
+	TaskID          string                        `json:"task_id"`
+	TaskType        TaskType                      `json:"task_type"`
+	AgentRole       AgentRole                     `json:"agent_role,omitempty"`
+	Error           string                        `json:"error"`
+	DefaultStrategy string                        `json:"default_strategy"`
+	Votes           []ConsensusVote               `json:"votes"`
+	Tally           map[consensusDecision]float64 `json:"tally"`
+	Decision        consensusDecision             `json:"decision"`
+	Reason          string                        `json:"reason"`
+	Explanation     string                        `json:"explanation"`
+	Outcome         string                        `json:"outcome"`
+	CreatedAt       time.Time                     `json:"created_at"`
+	ResolvedAt      *time.Time                    `json:"resolved_at,omitempty"`
+}
+
+// consensusAccuracy counts how often a provider's resolved votes were right.
+type consensusAccuracy struct {
+	correct int
+	samples int
+}
+
+// rate returns the smoothed accuracy, 0.5 with no history.
+func (a consensusAccuracy) rate() float64 {
+	return float64(a.correct+1) / float64(a.samples+2)
+}
+
+// weighConsensusVoters sets the weight of each provider's vote for a build.
+func (am *AgentManager) weighConsensusVoters(build *Build, providers []ai.AIProvider) map[ai.AIProvider]ConsensusVote {
+	config := consensusWeightsFromEnv()
+	scorecards := am.providerScorecardsForBuild(build, providers)
+	accuracy := am.consensusAccuracyForBuild(build)
+
+	weighted := make(map[ai.AIProvider]ConsensusVote, len(providers))
+	for _, provider := range providers {
+		vote := ConsensusVote{
+			Provider:        provider,
+			Capability:      consensusCapability(scorecards, provider),
+			Accuracy:        accuracy[provider].rate(),
+			AccuracySamples: accuracy[provider].samples,
+		}
+		vote.Weight = consensusVoteWeight(config, provider, vote.Capability, vote.Accuracy)
+		weighted[provider] = vote
+	}
+	return weighted
+}
+
+func consensusVoteWeight(config ConsensusWeights, provider ai.AIProvider, capability, accuracy float64) float64 {
+	base, ok := config.ProviderBase[provider]
+	if !ok {
+		base = 1
+	}
+	weight := base * (1 + config.CapabilityFactor*(capability-0.5)) * (1 + config.AccuracyFactor*(accuracy-0.5))
+	return math.Round(math.Max(weight, minConsensusVoteWeight)*1000) / 1000
+}
+
+// consensusCapability rates a provider's recovery judgement from its
+// diagnosis and repair scorecards.
+func consensusCapability(scorecards []ProviderScorecard, provider ai.AIProvider) float64 {
+	total, count := 0.0, 0
+	for _, scorecard := range scorecards {
+		if scorecard.Provider != provider || (scorecard.TaskShape != TaskShapeDiagnosis && scorecard.TaskShape != TaskShapeRepair) {
+			continue
+		}
+		total += (scorecard.RepairSuccessRate + scorecard.FirstPassVerificationRate) / 2
+		count++
+	}
+	if count == 0 {
+		return defaultConsensusCapability
+	}
+	return total / float64(count)
+}
+
+// consensusAccuracyForBuild scores providers on the resolved rounds of this
+// build and of the owner's recent builds.
+func (am *AgentManager) consensusAccuracyForBuild(build *Build) map[ai.AIProvider]consensusAccuracy {
+	stats := map[ai.AIProvider]consensusAccuracy{}
+	build.mu.RLock()
+	buildID, userID := build.ID, build.UserID
+	rounds := append([]ConsensusRound(nil), build.SnapshotState.ConsensusRounds...)
+	build.mu.RUnlock()
+	accumulateConsensusAccuracy(stats, rounds, "")
+
+	if am.db == nil || userID == 0 {
+		return stats
+	}
+	var snapshots []models.CompletedBuild
+	if err := am.db.Select("build_id", "status", "state_json").
+		Where("user_id = ? AND build_id <> ?", userID, buildID).
+		Order("updated_at DESC").
+		Limit(maxHistoricalBuildScan).
+		Find(&snapshots).Error; err != nil {
+		log.Printf("Build %s: consensus accuracy history unavailable: %v", buildID, err)
+		return stats
+	}
+	for _, snapshot := range snapshots {
+		accumulateConsensusAccuracy(stats, parseBuildSnapshotState(snapshot.StateJSON).ConsensusRounds, snapshot.Status)
+	}
+	return stats
+}
+
+// accumulateConsensusAccuracy adds resolved rounds to stats. A vote was
+// right when it agreed with a decision that recovered, or disagreed with one
+// that failed. Abort decisions and fallback votes say nothing about a
+// provider's judgement and are skipped.
+func accumulateConsensusAccuracy(stats map[ai.AIProvider]consensusAccuracy, rounds []ConsensusRound, buildStatus string) {
+	for _, round := range rounds {
+		outcome := effectiveConsensusOutcome(round, buildStatus)
+		if round.Decision == decisionAbort || (outcome != ConsensusOutcomeRecovered && outcome != ConsensusOutcomeFailed) {
+			continue
+		}
+		for _, vote := range round.Votes {
+			if vote.Fallback {
+				continue
+			}
+			entry := stats[vote.Provider]
+			entry.samples++
+			if (vote.Decision == round.Decision) == (outcome == ConsensusOutcomeRecovered) {
+				entry.correct++
+			}
+			stats[vote.Provider] = entry
+		}
+	}
+}
+
+// effectiveConsensusOutcome resolves a round still pending when its build
+// ended from the build's final status.
+func effectiveConsensusOutcome(round ConsensusRound, buildStatus string) string {
+	if round.Outcome != "" && round.Outcome != ConsensusOutcomePending {
+		return round.Outcome
+	}
+	switch BuildStatus(buildStatus) {
+	case BuildCompleted:
+		return ConsensusOutcomeRecovered
+	case BuildFailed:
+		return ConsensusOutcomeFailed
+	case BuildCancelled:
+		return ConsensusOutcomeInconclusive
+	}
+	return ConsensusOutcomePending
+}
+
+// tallyConsensusVotes picks the action holding more than half the vote
+// weight, backed by at least two votes. Without one, switch_provider is the
+// safest choice.
+func tallyConsensusVotes(votes []ConsensusVote) (consensusDecision, map[consensusDecision]float64, string) {
+	tally := map[consensusDecision]float64{}
+	counts := map[consensusDecision]int{}
+	total := 0.0
+	for _, vote := range votes {
+		tally[vote.Decision] += vote.Weight
+		counts[vote.Decision]++
+		total += vote.Weight
+	}
+
+	var winning consensusDecision
+	for decision, weight := range tally {
+		if winning == "" || weight > tally[winning] || (weight == tally[winning] && decision < winning) {
+			winning = decision
+		}
+	}
+	for decision, weight := range tally {
+		tally[decision] = math.Round(weight*1000) / 1000
+	}
+	if counts[winning] < 2 || tally[winning]*2 <= total {
+		return decisionSwitchProvider, tally, ConsensusReasonNoMajority
+	}
+	return winning, tally, ConsensusReasonWeightedMajority
+}
+
+// explainConsensusRound describes a round's decision in one sentence.
+func explainConsensusRound(round ConsensusRound) string {
+	total := 0.0
+	for _, weight := range round.Tally {
+		total += weight
+	}
+	backers := make([]string, 0, len(round.Votes))
+	for _, vote := range round.Votes {
+		if vote.Decision == round.Decision {
+			backers = append(backers, fmt.Sprintf("%s (%.2f)", vote.Provider, vote.Weight))
+		}
+	}
+	switch round.Reason {
+	case ConsensusReasonBudget:
+		return fmt.Sprintf("Voting stopped at the build's spend cap after %d vote(s); kept the default %s strategy.", len(round.Votes), round.DefaultStrategy)
+	case ConsensusReasonNoMajority:
+		return fmt.Sprintf("No action held a weighted majority of %.2f across %d vote(s); defaulted to %s.", total, len(round.Votes), round.Decision)
+	}
+	return fmt.Sprintf("%s chose %s with %.2f of %.2f vote weight.", strings.Join(backers, ", "), round.Decision, round.Tally[round.Decision], total)
+}
+
+// recordConsensusRound stores a finished round on the build snapshot and
+// persists it.
+func (am *AgentManager) recordConsensusRound(build *Build, agent *Agent, task *Task, taskErr error, defaultStrategy string, votes []ConsensusVote, decision consensusDecision, tally map[consensusDecision]float64, reason string) {
+	errText := taskErr.Error()
+	if len(errText) > maxConsensusErrorChars {
+		errText = errText[:maxConsensusErrorChars] + "..."
+	}
+	round := ConsensusRound{
+		ID:              uuid.New().String(),
+		TaskID:          task.ID,
+		TaskType:        task.Type,
+		Error:           errText,
+		DefaultStrategy: defaultStrategy,
+		Votes:           append([]ConsensusVote(nil), votes...),
+		Tally:           tally,
+		Decision:        decision,
+		Reason:          reason,
+		Outcome:         ConsensusOutcomePending,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if agent != nil {
+		round.AgentRole = agent.Role
+	}
+	round.Explanation = explainConsensusRound(round)
+
+	build.mu.Lock()
+	rounds := append(build.SnapshotState.ConsensusRounds, round)
+	if len(rounds) > maxConsensusRounds {
+		rounds = rounds[len(rounds)-maxConsensusRounds:]
+	}
+	build.SnapshotState.ConsensusRounds = rounds
+	build.mu.Unlock()
+	am.persistBuildSnapshot(build, nil)
+}
+
+// resolveConsensusRounds settles a task's pending rounds once the task
+// succeeds or gives up. A task giving up only fails rounds that chose to
+// retry it; solver and abort decisions are settled by the build's outcome.
+func (am *AgentManager) resolveConsensusRounds(build *Build, taskID string, recovered bool) {
+	if build == nil || taskID == "" {
+		return
+	}
+	now := time.Now().UTC()
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	for i := range build.SnapshotState.ConsensusRounds {
+		round := &build.SnapshotState.ConsensusRounds[i]
+		if round.TaskID != taskID || round.Outcome != ConsensusOutcomePending {
+			continue
+		}
+		switch {
+		case recovered:
+			round.Outcome = ConsensusOutcomeRecovered
+		case round.Decision == decisionRetrySame || round.Decision == decisionSwitchProvider:
+			round.Outcome = ConsensusOutcomeFailed
+		default:
+			continue
+		}
+		round.ResolvedAt = &now
+	}
+}
+
+// consensusRoundsView returns rounds newest first with pending outcomes
+// settled from the build's status.
+func consensusRoundsView(rounds []ConsensusRound, buildStatus string) []ConsensusRound {
+	view := make([]ConsensusRound, len(rounds))
+	for i, round := range rounds {
+		round.Outcome = effectiveConsensusOutcome(round, buildStatus)
+		view[i] = round
+	}
+	sort.SliceStable(view, func(i, j int) bool { return view[i].CreatedAt.After(view[j].CreatedAt) })
+	return view
+}
+
+// GetBuildConsensus returns the build's failure consensus rounds with each
+// vote, its weight and the decision taken.
+// GET /api/v1/builds/:buildId/consensus
+func (h *BuildHandler) GetBuildConsensus(c *gin.Context) {
+	buildID := c.Param("buildId")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	if build, err := h.manager.GetBuild(buildID); err == nil {
+		if uid != build.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+		build.mu.RLock()
+		rounds := append([]ConsensusRound(nil), build.SnapshotState.ConsensusRounds...)
+		status := string(build.Status)
+		build.mu.RUnlock()
+		c.JSON(http.StatusOK, gin.H{
+			"build_id": buildID,
+			"rounds":   consensusRoundsView(rounds, status),
+			"live":     true,
+		})
+		return
+	}
+
+	snapshot, err := h.getBuildSnapshot(uid, buildID)
+	if err != nil {
+		writeBuildLookupError(c, err, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"build_id": buildID,
+		"rounds":   consensusRoundsView(parseBuildSnapshotState(snapshot.StateJSON).ConsensusRounds, snapshot.Status),
+		"live":     false,
+	})
+}
@@ -0,0 +1,134 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+func TestTallyConsensusVotesUsesWeightNotHeadcount(t *testing.T) {
+	votes := []ConsensusVote{
+		{Provider: ai.ProviderClaude, Decision: decisionSpawnSolver, Weight: 1.8},
+		{Provider: ai.ProviderGPT4, Decision: decisionSpawnSolver, Weight: 1.2},
+		{Provider: ai.ProviderGemini, Decision: decisionRetrySame, Weight: 0.6},
+		{Provider: ai.ProviderGrok, Decision: decisionRetrySame, Weight: 0.5},
+		{Provider: ai.ProviderOllama, Decision: decisionRetrySame, Weight: 0.4},
+	}
+
+	decision, tally, reason := tallyConsensusVotes(votes)
+	if decision != decisionSpawnSolver {
+		t.Fatalf("expected weighted winner spawn_solver, got %q (tally %+v)", decision, tally)
+	}
+	if reason != ConsensusReasonWeightedMajority {
+		t.Fatalf("expected weighted_majority reason, got %q", reason)
+	}
+	if tally[decisionRetrySame] != 1.5 || tally[decisionSpawnSolver] != 3 {
+		t.Fatalf("unexpected tally %+v", tally)
+	}
+}
+
+func TestTallyConsensusVotesFallsBackWithoutMajority(t *testing.T) {
+	votes := []ConsensusVote{
+		{Provider: ai.ProviderClaude, Decision: decisionAbort, Weight: 2.5},
+		{Provider: ai.ProviderGPT4, Decision: decisionRetrySame, Weight: 1},
+		{Provider: ai.ProviderGemini, Decision: decisionSpawnSolver, Weight: 1},
+	}
+
+	// One heavy vote is not a majority on its own
+	decision, _, reason := tallyConsensusVotes(votes)
+	if decision != decisionSwitchProvider || reason != ConsensusReasonNoMajority {
+		t.Fatalf("expected switch_provider/no_majority, got %q/%q", decision, reason)
+	}
+}
+
+func TestConsensusVoteWeightScalesWithCapabilityAndAccuracy(t *testing.T) {
+	config := ConsensusWeights{CapabilityFactor: 1, AccuracyFactor: 1, ProviderBase: map[ai.AIProvider]float64{ai.ProviderClaude: 2}}
+
+	neutral := consensusVoteWeight(config, ai.ProviderGPT4, 0.5, 0.5)
+	if neutral != 1 {
+		t.Fatalf("expected neutral weight 1, got %v", neutral)
+	}
+	strong := consensusVoteWeight(config, ai.ProviderGPT4, 1, 1)
+	weak := consensusVoteWeight(config, ai.ProviderGPT4, 0, 0)
+	if strong <= neutral || weak >= neutral {
+		t.Fatalf("expected strong > neutral > weak, got %v, %v, %v", strong, neutral, weak)
+	}
+	if based := consensusVoteWeight(config, ai.ProviderClaude, 0.5, 0.5); based != 2 {
+		t.Fatalf("expected configured base weight 2, got %v", based)
+	}
+
+	config.CapabilityFactor, config.AccuracyFactor = 0, 0
+	if flat := consensusVoteWeight(config, ai.ProviderGPT4, 1, 0); flat != 1 {
+		t.Fatalf("expected zero factors to disable adjustments, got %v", flat)
+	}
+}
+
+func TestAccumulateConsensusAccuracyScoresResolvedRounds(t *testing.T) {
+	rounds := []ConsensusRound{
+		{
+			Decision: decisionSpawnSolver,
+			Outcome:  ConsensusOutcomeRecovered,
+			Votes: []ConsensusVote{
+				{Provider: ai.ProviderClaude, Decision: decisionSpawnSolver},
+				{Provider: ai.ProviderGPT4, Decision: decisionRetrySame},
+				{Provider: ai.ProviderGemini, Decision: decisionRetrySame, Fallback: true},
+			},
+		},
+		{
+			// Pending rounds take the build's outcome
+			Decision: decisionRetrySame,
+			Outcome:  ConsensusOutcomePending,
+			Votes: []ConsensusVote{
+				{Provider: ai.ProviderClaude, Decision: decisionSwitchProvider},
+				{Provider: ai.ProviderGPT4, Decision: decisionRetrySame},
+			},
+		},
+		{
+			Decision: decisionAbort,
+			Outcome:  ConsensusOutcomeFailed,
+			Votes:    []ConsensusVote{{Provider: ai.ProviderGPT4, Decision: decisionAbort}},
+		},
+	}
+
+	stats := map[ai.AIProvider]consensusAccuracy{}
+	accumulateConsensusAccuracy(stats, rounds, string(BuildFailed))
+
+	if got := stats[ai.ProviderClaude]; got.correct != 2 || got.samples != 2 {
+		t.Fatalf("expected claude 2/2, got %+v", got)
+	}
+	if got := stats[ai.ProviderGPT4]; got.correct != 0 || got.samples != 2 {
+		t.Fatalf("expected gpt4 0/2, got %+v", got)
+	}
+	if _, ok := stats[ai.ProviderGemini]; ok {
+		t.Fatalf("expected fallback vote to be skipped, got %+v", stats[ai.ProviderGemini])
+	}
+}
+
+func TestResolveConsensusRoundsSettlesTaskRounds(t *testing.T) {
+	am := &AgentManager{}
+	build := &Build{ID: "build-consensus"}
+	build.SnapshotState.ConsensusRounds = []ConsensusRound{
+		{ID: "r1", TaskID: "task-1", Decision: decisionRetrySame, Outcome: ConsensusOutcomePending, CreatedAt: time.Now().Add(-time.Minute)},
+		{ID: "r2", TaskID: "task-1", Decision: decisionSpawnSolver, Outcome: ConsensusOutcomePending, CreatedAt: time.Now()},
+		{ID: "r3", TaskID: "task-2", Decision: decisionRetrySame, Outcome: ConsensusOutcomePending},
+	}
+
+	am.resolveConsensusRounds(build, "task-1", false)
+
+	rounds := build.SnapshotState.ConsensusRounds
+	if rounds[0].Outcome != ConsensusOutcomeFailed || rounds[0].ResolvedAt == nil {
+		t.Fatalf("expected retry round to fail, got %+v", rounds[0])
+	}
+	if rounds[1].Outcome != ConsensusOutcomePending {
+		t.Fatalf("expected solver round to wait for the build outcome, got %q", rounds[1].Outcome)
+	}
+	if rounds[2].Outcome != ConsensusOutcomePending {
+		t.Fatalf("expected other task's round untouched, got %q", rounds[2].Outcome)
+	}
+
+	view := consensusRoundsView(rounds, string(BuildCompleted))
+	if view[0].ID != "r2" || view[0].Outcome != ConsensusOutcomeRecovered {
+		t.Fatalf("expected newest round first and settled by build status, got %+v", view[0])
+	}
+}
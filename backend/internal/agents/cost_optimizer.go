@@ -0,0 +1,357 @@
+package agents
+
+import (
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/pricing"
+	"apex-build/pkg/models"
+)
+
+// Outcomes of a budget mode attempt.
+const (
+	CostAttemptPending    = "pending"    // the task has not finished
+	CostAttemptSucceeded  = "succeeded"  // the task succeeded with this attempt's output
+	CostAttemptFailed     = "failed"     // the attempt's output was rejected
+	CostAttemptUnresolved = "unresolved" // the build ended before the task reported back
+)
+
+// Reasons budget mode chose a model.
+const (
+	BudgetReasonCheapestProven = "cheapest_proven"    // cheapest model that has succeeded at this role and task type
+	BudgetReasonNoHistory      = "no_success_history" // no model has succeeded yet, so the cheapest untried one
+	BudgetReasonEscalated      = "escalated_after_failure"
+)
+
+const (
+	maxModelTaskStats           = 200
+	maxCostOptimizationAttempts = 200
+	// budgetProvenSuccessRate is the success rate a model needs before budget
+	// mode starts a task on it.
+	budgetProvenSuccessRate = 0.5
+	// budgetKnownBadAttempts is how many unproven attempts rule a model out
+	// as a starting point.
+	budgetKnownBadAttempts = 2
+)
+
+// budgetTierLadder is the order budget mode escalates through.
+var budgetTierLadder = []PowerMode{PowerFast, PowerBalanced, PowerMax}
+
+// ModelTaskStat counts how often a model succeeded at one role's task type.
+type ModelTaskStat struct {
+	AgentRole AgentRole     `json:"agent_role"`
+	TaskType  TaskType      `json:"task_type"`
+	Provider  ai.AIProvider `json:"provider"`
+	Model     string        `json:"model"`
+	Attempts  int           `json:"attempts"`
+	Successes int           `json:"successes"`
+}
+
+func (s ModelTaskStat) successRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Attempts)
+}
+
+func modelTaskStatKey(role AgentRole, taskType TaskType, provider ai.AIProvider, model string) string {
+	return strings.Join([]string{string(role), string(taskType), string(provider), strings.ToLower(strings.TrimSpace(model))}, "|")
+}
+
+// CostOptimizationAttempt is one generation call routed by budget mode.
+type CostOptimizationAttempt struct {
+	TaskID           string        `json:"task_id"`
+	TaskType         TaskType      `json:"task_type"`
+	AgentRole        AgentRole     `json:"agent_role"`
+	Provider         ai.AIProvider `json:"provider"`
+	Model            string        `json:"model"`
+	Tier             PowerMode     `json:"tier"`
+	Reason           string        `json:"reason"`
+	ProjectedCostUSD float64       `json:"projected_cost_usd"`
+	ActualCostUSD    float64       `json:"actual_cost_usd"`
+	Outcome          string        `json:"outcome"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// CostOptimizationReport compares what budget mode expected to spend with
+// what the build actually spent. Projected cost is estimated before each call
+// from the prompt size and the output token limit; actual cost is what the
+// call was billed. Totals cover every attempt, while Attempts keeps the
+// newest 200.
+type CostOptimizationReport struct {
+	TotalAttempts     int                       `json:"total_attempts"`
+	Escalations       int                       `json:"escalations"`
+	TierCalls         map[PowerMode]int         `json:"tier_calls"`
+	ProjectedCostUSD  float64                   `json:"projected_cost_usd"`
+	ActualCostUSD     float64                   `json:"actual_cost_usd"`
+	EscalationCostUSD float64                   `json:"escalation_cost_usd"`
+	VarianceUSD       float64                   `json:"variance_usd"`
+	Attempts          []CostOptimizationAttempt `json:"attempts"`
+	FinalizedAt       *time.Time                `json:"finalized_at,omitempty"`
+}
+
+// budgetRoute is the model budget mode picked for a task attempt.
+type budgetRoute struct {
+	Tier   PowerMode
+	Model  string
+	Reason string
+}
+
+type budgetRung struct {
+	Tier  PowerMode
+	Model string
+}
+
+// budgetModelLadder lists a provider's models from cheapest to strongest,
+// stopping at the plan's highest power mode.
+func budgetModelLadder(build *Build, provider ai.AIProvider) []budgetRung {
+	maxMode := PowerBalanced
+	if build != nil {
+		build.mu.RLock()
+		if policy := build.SnapshotState.PolicyState; policy != nil && policy.MaxPowerMode != "" {
+			maxMode = policy.MaxPowerMode
+		}
+		build.mu.RUnlock()
+	}
+
+	ladder := make([]budgetRung, 0, len(budgetTierLadder))
+	for _, tier := range budgetTierLadder {
+		if modeRank(tier) > modeRank(maxMode) {
+			break
+		}
+		model := selectModelForPowerMode(provider, tier)
+		if model == "" || len(ladder) > 0 && strings.EqualFold(ladder[len(ladder)-1].Model, model) {
+			continue
+		}
+		ladder = append(ladder, budgetRung{Tier: tier, Model: model})
+	}
+	return ladder
+}
+
+// planBudgetRoute picks the cheapest model that has succeeded at the task's
+// role and type, then moves one step up the ladder for each failed attempt.
+func (am *AgentManager) planBudgetRoute(build *Build, role AgentRole, task *Task, provider ai.AIProvider) budgetRoute {
+	ladder := budgetModelLadder(build, provider)
+	if len(ladder) == 0 {
+		return budgetRoute{Tier: PowerFast, Reason: BudgetReasonNoHistory}
+	}
+	history := am.modelTaskHistory(build)
+	start, reason := chooseBudgetStartRung(ladder, history, role, task.Type, provider)
+
+	rung := start
+	if task.RetryCount > 0 {
+		rung = min(start+task.RetryCount, len(ladder)-1)
+		if rung > start {
+			reason = BudgetReasonEscalated
+		}
+	}
+	return budgetRoute{Tier: ladder[rung].Tier, Model: ladder[rung].Model, Reason: reason}
+}
+
+func chooseBudgetStartRung(ladder []budgetRung, history map[string]ModelTaskStat, role AgentRole, taskType TaskType, provider ai.AIProvider) (int, string) {
+	firstUntried := -1
+	for i, rung := range ladder {
+		stat := history[modelTaskStatKey(role, taskType, provider, rung.Model)]
+		if stat.Successes > 0 && stat.successRate() >= budgetProvenSuccessRate {
+			return i, BudgetReasonCheapestProven
+		}
+		if firstUntried < 0 && stat.Attempts < budgetKnownBadAttempts {
+			firstUntried = i
+		}
+	}
+	if firstUntried < 0 {
+		return len(ladder) - 1, BudgetReasonNoHistory
+	}
+	return firstUntried, BudgetReasonNoHistory
+}
+
+// modelTaskHistory merges this build's model outcomes with those of the
+// owner's recent builds.
+func (am *AgentManager) modelTaskHistory(build *Build) map[string]ModelTaskStat {
+	historical := am.historicalModelTaskStats(build)
+
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+	merged := make(map[string]ModelTaskStat, len(historical)+len(build.SnapshotState.ModelTaskStats))
+	for key, stat := range historical {
+		merged[key] = stat
+	}
+	for _, stat := range build.SnapshotState.ModelTaskStats {
+		key := modelTaskStatKey(stat.AgentRole, stat.TaskType, stat.Provider, stat.Model)
+		entry := merged[key]
+		entry.Attempts += stat.Attempts
+		entry.Successes += stat.Successes
+		merged[key] = entry
+	}
+	return merged
+}
+
+func (am *AgentManager) historicalModelTaskStats(build *Build) map[string]ModelTaskStat {
+	build.mu.RLock()
+	cached := build.ModelHistory
+	buildID, userID := build.ID, build.UserID
+	build.mu.RUnlock()
+	if cached != nil {
+		return cached
+	}
+
+	history := map[string]ModelTaskStat{}
+	if am.db != nil && userID != 0 {
+		var snapshots []models.CompletedBuild
+		if err := am.db.Select("build_id", "state_json").
+			Where("user_id = ? AND build_id <> ?", userID, buildID).
+			Order("updated_at DESC").
+			Limit(maxHistoricalBuildScan).
+			Find(&snapshots).Error; err != nil {
+			log.Printf("Build %s: model success history unavailable: %v", buildID, err)
+		}
+		for _, snapshot := range snapshots {
+			for _, stat := range parseBuildSnapshotState(snapshot.StateJSON).ModelTaskStats {
+				key := modelTaskStatKey(stat.AgentRole, stat.TaskType, stat.Provider, stat.Model)
+				entry := history[key]
+				entry.Attempts += stat.Attempts
+				entry.Successes += stat.Successes
+				history[key] = entry
+			}
+		}
+	}
+
+	build.mu.Lock()
+	if build.ModelHistory == nil {
+		build.ModelHistory = history
+	}
+	history = build.ModelHistory
+	build.mu.Unlock()
+	return history
+}
+
+// recordBudgetAttempt adds a budget mode call to the build's cost report.
+func (am *AgentManager) recordBudgetAttempt(build *Build, agent *Agent, task *Task, route budgetRoute, provider ai.AIProvider, model string, promptChars int, maxTokens int, usage *ai.Usage) {
+	isBYOK := !am.buildUsesPlatformKeys(build)
+	attempt := CostOptimizationAttempt{
+		TaskID:           task.ID,
+		TaskType:         task.Type,
+		AgentRole:        agent.Role,
+		Provider:         provider,
+		Model:            model,
+		Tier:             route.Tier,
+		Reason:           route.Reason,
+		ProjectedCostUSD: pricing.Get().EstimateCost(string(provider), model, promptChars, maxTokens, string(route.Tier), isBYOK),
+		Outcome:          CostAttemptPending,
+		CreatedAt:        time.Now().UTC(),
+	}
+	if usage != nil {
+		attempt.ActualCostUSD = usage.Cost
+	}
+
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	report := build.SnapshotState.CostOptimization
+	if report == nil {
+		report = &CostOptimizationReport{TierCalls: map[PowerMode]int{}}
+		build.SnapshotState.CostOptimization = report
+	}
+	report.TotalAttempts++
+	report.TierCalls[attempt.Tier]++
+	report.ProjectedCostUSD = roundCostUSD(report.ProjectedCostUSD + attempt.ProjectedCostUSD)
+	report.ActualCostUSD = roundCostUSD(report.ActualCostUSD + attempt.ActualCostUSD)
+	if attempt.Reason == BudgetReasonEscalated {
+		report.Escalations++
+		report.EscalationCostUSD = roundCostUSD(report.EscalationCostUSD + attempt.ActualCostUSD)
+	}
+	report.Attempts = append(report.Attempts, attempt)
+	if len(report.Attempts) > maxCostOptimizationAttempts {
+		report.Attempts = report.Attempts[len(report.Attempts)-maxCostOptimizationAttempts:]
+	}
+}
+
+// recordModelTaskOutcome counts a finished task toward its model's success
+// rate. Budget mode attempts waiting on the task are settled and counted
+// under the model they used; otherwise the model that produced the output,
+// or the agent's, is counted.
+func recordModelTaskOutcome(build *Build, agent *Agent, task *Task, output *TaskOutput, provider ai.AIProvider, success bool) {
+	outcome := CostAttemptFailed
+	if success {
+		outcome = CostAttemptSucceeded
+	}
+
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	settled := false
+	if report := build.SnapshotState.CostOptimization; report != nil {
+		for i := range report.Attempts {
+			attempt := &report.Attempts[i]
+			if attempt.TaskID != task.ID || attempt.Outcome != CostAttemptPending {
+				continue
+			}
+			attempt.Outcome = outcome
+			addModelTaskStatLocked(build, attempt.AgentRole, attempt.TaskType, attempt.Provider, attempt.Model, success)
+			settled = true
+		}
+	}
+	if settled {
+		return
+	}
+	model := firstNonEmptyString(taskOutputMetricString(output, "model"), agent.Model)
+	if provider == "" || model == "" {
+		return
+	}
+	addModelTaskStatLocked(build, agent.Role, task.Type, provider, model, success)
+}
+
+func addModelTaskStatLocked(build *Build, role AgentRole, taskType TaskType, provider ai.AIProvider, model string, success bool) {
+	key := modelTaskStatKey(role, taskType, provider, model)
+	stats := build.SnapshotState.ModelTaskStats
+	for i := range stats {
+		if modelTaskStatKey(stats[i].AgentRole, stats[i].TaskType, stats[i].Provider, stats[i].Model) != key {
+			continue
+		}
+		stats[i].Attempts++
+		if success {
+			stats[i].Successes++
+		}
+		return
+	}
+	if len(stats) >= maxModelTaskStats {
+		return
+	}
+	stat := ModelTaskStat{AgentRole: role, TaskType: taskType, Provider: provider, Model: model, Attempts: 1}
+	if success {
+		stat.Successes = 1
+	}
+	build.SnapshotState.ModelTaskStats = append(stats, stat)
+}
+
+// finalizeCostOptimizationReport closes a budget mode build's cost report
+// and returns a copy, or nil for builds in other power modes.
+func (am *AgentManager) finalizeCostOptimizationReport(build *Build, now time.Time) *CostOptimizationReport {
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	report := build.SnapshotState.CostOptimization
+	if report == nil {
+		return nil
+	}
+	for i := range report.Attempts {
+		if report.Attempts[i].Outcome == CostAttemptPending {
+			report.Attempts[i].Outcome = CostAttemptUnresolved
+		}
+	}
+	report.VarianceUSD = roundCostUSD(report.ActualCostUSD - report.ProjectedCostUSD)
+	finalizedAt := now.UTC()
+	report.FinalizedAt = &finalizedAt
+
+	clone := *report
+	clone.Attempts = append([]CostOptimizationAttempt(nil), report.Attempts...)
+	clone.TierCalls = make(map[PowerMode]int, len(report.TierCalls))
+	for tier, calls := range report.TierCalls {
+		clone.TierCalls[tier] = calls
+	}
+	return &clone
+}
+
+func roundCostUSD(value float64) float64 {
+	return math.Round(value*1_000_000) / 1_000_000
+}
@@ -0,0 +1,124 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+func newBudgetTestBuild(maxMode PowerMode) *Build {
+	build := &Build{ID: "build-budget", PowerMode: PowerBudget}
+	build.SnapshotState.PolicyState = &BuildPolicyState{MaxPowerMode: maxMode}
+	return build
+}
+
+func TestPlanBudgetRouteStartsCheapestAndEscalatesAfterFailures(t *testing.T) {
+	am := &AgentManager{}
+	build := newBudgetTestBuild(PowerMax)
+	task := &Task{ID: "task-1", Type: TaskGenerateUI}
+
+	route := am.planBudgetRoute(build, RoleFrontend, task, ai.ProviderClaude)
+	if route.Tier != PowerFast || route.Model != selectModelForPowerMode(ai.ProviderClaude, PowerFast) {
+		t.Fatalf("expected the cheapest claude model first, got %+v", route)
+	}
+	if route.Reason != BudgetReasonNoHistory {
+		t.Fatalf("expected no_success_history reason, got %q", route.Reason)
+	}
+
+	task.RetryCount = 1
+	route = am.planBudgetRoute(build, RoleFrontend, task, ai.ProviderClaude)
+	if route.Tier != PowerBalanced || route.Reason != BudgetReasonEscalated {
+		t.Fatalf("expected escalation to balanced after one failure, got %+v", route)
+	}
+
+	task.RetryCount = 5
+	route = am.planBudgetRoute(build, RoleFrontend, task, ai.ProviderClaude)
+	if route.Tier != PowerMax {
+		t.Fatalf("expected escalation to stop at max, got %+v", route)
+	}
+}
+
+func TestPlanBudgetRouteCapsEscalationAtPlanPowerMode(t *testing.T) {
+	am := &AgentManager{}
+	build := newBudgetTestBuild(PowerBalanced)
+	task := &Task{ID: "task-1", Type: TaskGenerateAPI, RetryCount: 3}
+
+	route := am.planBudgetRoute(build, RoleBackend, task, ai.ProviderGPT4)
+	if route.Tier != PowerBalanced || route.Model != selectModelForPowerMode(ai.ProviderGPT4, PowerBalanced) {
+		t.Fatalf("expected builder plan to cap escalation at balanced, got %+v", route)
+	}
+}
+
+func TestPlanBudgetRouteUsesCheapestProvenModel(t *testing.T) {
+	am := &AgentManager{}
+	build := newBudgetTestBuild(PowerMax)
+	fastModel := selectModelForPowerMode(ai.ProviderClaude, PowerFast)
+	balancedModel := selectModelForPowerMode(ai.ProviderClaude, PowerBalanced)
+	build.ModelHistory = map[string]ModelTaskStat{
+		modelTaskStatKey(RoleBackend, TaskGenerateAPI, ai.ProviderClaude, fastModel):     {Attempts: 4, Successes: 1},
+		modelTaskStatKey(RoleBackend, TaskGenerateAPI, ai.ProviderClaude, balancedModel): {Attempts: 3, Successes: 3},
+	}
+
+	route := am.planBudgetRoute(build, RoleBackend, &Task{ID: "task-1", Type: TaskGenerateAPI}, ai.ProviderClaude)
+	if route.Model != balancedModel || route.Reason != BudgetReasonCheapestProven {
+		t.Fatalf("expected the proven balanced model, got %+v", route)
+	}
+
+	// The fast model's record for another task type does not carry over
+	route = am.planBudgetRoute(build, RoleBackend, &Task{ID: "task-2", Type: TaskGenerateSchema}, ai.ProviderClaude)
+	if route.Model != fastModel {
+		t.Fatalf("expected the cheapest model for an unseen task type, got %+v", route)
+	}
+}
+
+func TestBudgetAttemptsSettleIntoStatsAndReport(t *testing.T) {
+	am := &AgentManager{}
+	build := newBudgetTestBuild(PowerMax)
+	agent := &Agent{ID: "agent-1", Role: RoleFrontend, Provider: ai.ProviderClaude}
+	task := &Task{ID: "task-1", Type: TaskGenerateUI}
+	fastModel := selectModelForPowerMode(ai.ProviderClaude, PowerFast)
+	balancedModel := selectModelForPowerMode(ai.ProviderClaude, PowerBalanced)
+
+	am.recordBudgetAttempt(build, agent, task, budgetRoute{Tier: PowerFast, Model: fastModel, Reason: BudgetReasonNoHistory}, ai.ProviderClaude, fastModel, 4000, 2000, &ai.Usage{Cost: 0.002})
+	recordModelTaskOutcome(build, agent, task, nil, ai.ProviderClaude, false)
+
+	task.RetryCount = 1
+	am.recordBudgetAttempt(build, agent, task, budgetRoute{Tier: PowerBalanced, Model: balancedModel, Reason: BudgetReasonEscalated}, ai.ProviderClaude, balancedModel, 4000, 2000, &ai.Usage{Cost: 0.03})
+	recordModelTaskOutcome(build, agent, task, nil, ai.ProviderClaude, true)
+
+	stats := map[string]ModelTaskStat{}
+	for _, stat := range build.SnapshotState.ModelTaskStats {
+		stats[stat.Model] = stat
+	}
+	if got := stats[fastModel]; got.Attempts != 1 || got.Successes != 0 {
+		t.Fatalf("expected the fast model to record a failure, got %+v", got)
+	}
+	if got := stats[balancedModel]; got.Attempts != 1 || got.Successes != 1 {
+		t.Fatalf("expected the balanced model to record a success, got %+v", got)
+	}
+
+	report := am.finalizeCostOptimizationReport(build, time.Now())
+	if report == nil || report.FinalizedAt == nil {
+		t.Fatal("expected a finalized cost report")
+	}
+	if report.TotalAttempts != 2 || report.Escalations != 1 {
+		t.Fatalf("expected 2 attempts and 1 escalation, got %+v", report)
+	}
+	if report.ActualCostUSD != 0.032 || report.EscalationCostUSD != 0.03 {
+		t.Fatalf("unexpected actual costs %+v", report)
+	}
+	if report.ProjectedCostUSD <= 0 || report.VarianceUSD != roundCostUSD(report.ActualCostUSD-report.ProjectedCostUSD) {
+		t.Fatalf("expected projected cost and variance, got %+v", report)
+	}
+	if report.Attempts[0].Outcome != CostAttemptFailed || report.Attempts[1].Outcome != CostAttemptSucceeded {
+		t.Fatalf("unexpected attempt outcomes %+v", report.Attempts)
+	}
+	if report.TierCalls[PowerFast] != 1 || report.TierCalls[PowerBalanced] != 1 {
+		t.Fatalf("unexpected tier calls %+v", report.TierCalls)
+	}
+
+	if am.finalizeCostOptimizationReport(&Build{ID: "build-balanced", PowerMode: PowerBalanced}, time.Now()) != nil {
+		t.Fatal("expected no cost report outside budget mode")
+	}
+}
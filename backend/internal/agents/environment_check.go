@@ -0,0 +1,40 @@
+package agents
+
+import (
+	"log"
+
+	"apex-build/internal/envcheck"
+)
+
+// checkEnvironmentVariables compares the environment variables the build's
+// code reads with the linked project's environments, or the owner's
+// account-wide secrets when there is no project yet. Missing variables are
+// recorded on the snapshot so the completed build lists them. The check
+// only informs, so a failure is logged and yields nil.
+func (am *AgentManager) checkEnvironmentVariables(build *Build, files []GeneratedFile) *envcheck.Report {
+	if am.db == nil {
+		return nil
+	}
+	contents := make(map[string]string, len(files))
+	for _, f := range files {
+		contents[f.Path] = f.Content
+	}
+	var projectID uint
+	if build.ProjectID != nil {
+		projectID = *build.ProjectID
+	}
+
+	report, err := envcheck.NewChecker(am.db, nil).Check(build.UserID, projectID, contents)
+	if err != nil {
+		log.Printf("Build %s: failed to check environment variables: %v", build.ID, err)
+		return nil
+	}
+	build.mu.Lock()
+	build.SnapshotState.EnvVarCheck = report
+	build.mu.Unlock()
+
+	if report.Warning != "" {
+		log.Printf("Build %s: %s", build.ID, report.Warning)
+	}
+	return report
+}
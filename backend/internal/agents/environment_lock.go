@@ -0,0 +1,90 @@
+package agents
+
+import (
+	"log"
+	"time"
+
+	"apex-build/internal/envlock"
+	"apex-build/pkg/models"
+)
+
+// attachEnvironmentLock writes apex.lock into the build's files. When the
+// build replaces a lock, from its own output or the linked project, the
+// differences are recorded as environment drift so the completed build can
+// warn that the project no longer matches the environment it was locked to.
+// The drift is returned for the completion broadcast.
+func (am *AgentManager) attachEnvironmentLock(build *Build, files []GeneratedFile, completedAt time.Time) ([]GeneratedFile, []envlock.Drift) {
+	contents := make(map[string]string, len(files))
+	previous := ""
+	for _, f := range files {
+		if envlock.IsLockPath(f.Path) {
+			previous = f.Content
+			continue
+		}
+		contents[f.Path] = f.Content
+	}
+	if previous == "" {
+		previous = am.projectEnvironmentLock(build)
+	}
+
+	lock := envlock.Capture(contents, envlock.SourceBuild)
+	lock.GeneratedAt = completedAt.UTC()
+	content, err := lock.Encode()
+	if err != nil {
+		log.Printf("Build %s: failed to encode %s: %v", build.ID, envlock.FileName, err)
+		return files, nil
+	}
+
+	var drift []envlock.Drift
+	if previous != "" {
+		if locked, err := envlock.Parse(previous); err == nil {
+			drift = envlock.Compare(locked, lock)
+		}
+	}
+
+	lockFile := GeneratedFile{
+		Path:     envlock.FileName,
+		Content:  content,
+		Language: "json",
+		Size:     int64(len(content)),
+		IsNew:    previous == "",
+	}
+
+	build.mu.Lock()
+	kept := build.SnapshotFiles[:0]
+	for _, f := range build.SnapshotFiles {
+		if !envlock.IsLockPath(f.Path) {
+			kept = append(kept, f)
+		}
+	}
+	build.SnapshotFiles = append(kept, lockFile)
+	build.SnapshotState.EnvironmentDrift = drift
+	build.mu.Unlock()
+
+	if len(drift) > 0 {
+		log.Printf("Build %s: %s", build.ID, envlock.Summary(drift))
+	}
+
+	out := make([]GeneratedFile, 0, len(files)+1)
+	for _, f := range files {
+		if !envlock.IsLockPath(f.Path) {
+			out = append(out, f)
+		}
+	}
+	return append(out, lockFile), drift
+}
+
+// projectEnvironmentLock returns the apex.lock stored in the build's linked
+// project, if any.
+func (am *AgentManager) projectEnvironmentLock(build *Build) string {
+	if am.db == nil || build.ProjectID == nil || *build.ProjectID == 0 {
+		return ""
+	}
+	var file models.File
+	if err := am.db.Select("content").
+		Where("project_id = ? AND path IN ?", *build.ProjectID, []string{envlock.FileName, "/" + envlock.FileName}).
+		First(&file).Error; err != nil {
+		return ""
+	}
+	return file.Content
+}
@@ -0,0 +1,47 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"apex-build/internal/envlock"
+)
+
+func TestAttachEnvironmentLockRecordsDriftFromPreviousLock(t *testing.T) {
+	previous, err := (&envlock.Lock{
+		Version:   envlock.LockfileVersion,
+		Languages: []envlock.Language{{Name: "node", Version: "18", Source: "package.json"}},
+		EnvVars:   []string{"DATABASE_URL"},
+	}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := &Build{ID: "build-lock"}
+	files := []GeneratedFile{
+		{Path: "package.json", Content: `{"engines":{"node":"20"}}`},
+		{Path: "server.js", Content: "db(process.env.DATABASE_URL)"},
+		{Path: "/apex.lock", Content: previous},
+	}
+
+	am := &AgentManager{}
+	out, drift := am.attachEnvironmentLock(build, files, time.Now())
+	if len(out) != len(files) || out[len(out)-1].Path != envlock.FileName {
+		t.Fatalf("expected the old lock replaced, got %+v", out)
+	}
+	lock, err := envlock.Parse(out[len(out)-1].Content)
+	if err != nil || lock.GeneratedBy != envlock.SourceBuild || lock.Languages[0].Version != "20" {
+		t.Fatalf("lock = %+v, err = %v", lock, err)
+	}
+	if len(drift) != 1 || drift[0].Name != "node" || drift[0].Locked != "18" || drift[0].Current != "20" {
+		t.Fatalf("expected node drift, got %+v", drift)
+	}
+	if len(build.SnapshotFiles) != 1 || len(build.SnapshotState.EnvironmentDrift) != 1 {
+		t.Fatalf("expected lock and drift on the snapshot, got %d files, %+v", len(build.SnapshotFiles), build.SnapshotState.EnvironmentDrift)
+	}
+
+	// A first build has nothing to drift from
+	fresh := &Build{ID: "build-fresh"}
+	if _, drift := am.attachEnvironmentLock(fresh, files[:2], time.Now()); len(drift) != 0 {
+		t.Fatalf("expected no drift without a previous lock, got %+v", drift)
+	}
+}
@@ -0,0 +1,285 @@
+package agents
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// planFileBudgets caps the distinct files a build may produce, by
+// subscription plan. Scaffold files count toward the budget.
+var planFileBudgets = map[string]int{
+	"free":       30,
+	"builder":    60,
+	"pro":        90,
+	"team":       120,
+	"enterprise": 150,
+	"owner":      200,
+}
+
+const (
+	defaultBuildFileBudget = 30
+	// minBuildFileBudget keeps a user-requested budget large enough for the
+	// scaffold plus a minimal app.
+	minBuildFileBudget = 12
+)
+
+// resolveBuildFileBudget returns the file budget for a plan tier. A requested
+// budget can only tighten the tier's cap, and never below minBuildFileBudget.
+func resolveBuildFileBudget(subscriptionPlan string, requested int) int {
+	budget, ok := planFileBudgets[strings.ToLower(strings.TrimSpace(subscriptionPlan))]
+	if !ok {
+		budget = defaultBuildFileBudget
+	}
+	if requested > 0 && requested < budget {
+		budget = requested
+		if budget < minBuildFileBudget {
+			budget = minBuildFileBudget
+		}
+	}
+	return budget
+}
+
+// fileBudgetPlanningDirective tells the planner how many files the build may
+// contain so it scopes the plan to fit.
+func fileBudgetPlanningDirective(budget int) string {
+	if budget <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(`APEX BUILD FILE BUDGET:
+- This build may contain at most %d files in total, including scaffold and config files.
+- Scope features and the file manifest to fit. Prefer fewer, cohesive files over many small ones.
+- If the request cannot fit, keep the core user flow and defer secondary features.`, budget)
+}
+
+// plannedFileTrimRank orders planned files for trimming: higher ranks go
+// first. Protected scaffold and required files are never trimmed.
+func plannedFileTrimRank(file PlannedFile) int {
+	path := strings.ToLower(file.Path)
+	switch {
+	case strings.Contains(path, ".test.") || strings.Contains(path, ".spec.") ||
+		strings.HasPrefix(path, "tests/") || strings.Contains(path, "/__tests__/"):
+		return 5
+	case strings.HasSuffix(path, ".md") || strings.HasPrefix(path, "docs/"):
+		return 5
+	}
+	switch strings.ToLower(strings.TrimSpace(file.Type)) {
+	case "config":
+		return 0
+	case "database":
+		return 1
+	case "backend":
+		return 2
+	case "frontend":
+		return 3
+	}
+	return 4
+}
+
+// fitPlanToFileBudget trims the plan's file manifest to budget and drops the
+// trimmed paths from the work orders. Scaffold files and files a work order
+// requires are kept even when they alone exceed the budget. It records the
+// budget and trimmed paths on the plan and returns the trimmed paths.
+func fitPlanToFileBudget(plan *BuildPlan, budget int) []string {
+	if plan == nil || budget <= 0 {
+		return nil
+	}
+	plan.FileBudget = budget
+
+	protected := make(map[string]bool)
+	for _, file := range plan.ScaffoldFiles {
+		protected[file.Path] = true
+	}
+	for _, order := range plan.WorkOrders {
+		for _, path := range order.RequiredFiles {
+			protected[path] = true
+		}
+	}
+
+	total := len(plan.Files)
+	for path := range protected {
+		if !plannedFilesContain(plan.Files, path) {
+			total++
+		}
+	}
+	if total <= budget {
+		return nil
+	}
+
+	candidates := make([]PlannedFile, 0, len(plan.Files))
+	for _, file := range plan.Files {
+		if !protected[file.Path] {
+			candidates = append(candidates, file)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return plannedFileTrimRank(candidates[i]) > plannedFileTrimRank(candidates[j])
+	})
+	excess := total - budget
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	trimmed := make(map[string]bool, excess)
+	trimmedPaths := make([]string, 0, excess)
+	for _, file := range candidates[:excess] {
+		trimmed[file.Path] = true
+		trimmedPaths = append(trimmedPaths, file.Path)
+	}
+	sort.Strings(trimmedPaths)
+
+	kept := plan.Files[:0]
+	for _, file := range plan.Files {
+		if !trimmed[file.Path] {
+			kept = append(kept, file)
+		}
+	}
+	plan.Files = kept
+	for i := range plan.WorkOrders {
+		plan.WorkOrders[i].OwnedFiles = filterTrimmedPaths(plan.WorkOrders[i].OwnedFiles, trimmed)
+	}
+	plan.TrimmedFiles = dedupeStrings(append(plan.TrimmedFiles, trimmedPaths...))
+	plan.SpecHash = hashBuildPlan(plan)
+	return trimmedPaths
+}
+
+func plannedFilesContain(files []PlannedFile, path string) bool {
+	for _, file := range files {
+		if file.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func filterTrimmedPaths(paths []string, trimmed map[string]bool) []string {
+	if len(paths) == 0 {
+		return paths
+	}
+	out := paths[:0]
+	for _, path := range paths {
+		if !trimmed[path] {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// enforceBuildFileBudget drops new files from a task's output once the build
+// would exceed its file budget. Edits to existing files always pass. Files
+// the plan expects are kept ahead of unplanned ones. Dropped paths are added
+// to the plan's trimmed scope and reported to the user.
+func (am *AgentManager) enforceBuildFileBudget(build *Build, task *Task, output *TaskOutput) []string {
+	if am == nil || build == nil || output == nil || len(output.Files) == 0 {
+		return nil
+	}
+	build.mu.RLock()
+	budget := build.FileBudget
+	planned := make(map[string]bool)
+	if build.Plan != nil {
+		for _, file := range build.Plan.Files {
+			planned[file.Path] = true
+		}
+		for _, order := range build.Plan.WorkOrders {
+			for _, path := range order.OwnedFiles {
+				planned[path] = true
+			}
+		}
+	}
+	build.mu.RUnlock()
+	if budget <= 0 {
+		return nil
+	}
+
+	existing := generatedFileMap(am.collectGeneratedFiles(build))
+	var newPlanned, newUnplanned []string
+	seen := make(map[string]bool)
+	for _, file := range output.Files {
+		path := sanitizeFilePath(file.Path)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if _, ok := existing[path]; ok {
+			continue
+		}
+		if planned[path] {
+			newPlanned = append(newPlanned, path)
+		} else {
+			newUnplanned = append(newUnplanned, path)
+		}
+	}
+	remaining := budget - len(existing)
+	if remaining < 0 {
+		remaining = 0
+	}
+	if len(newPlanned)+len(newUnplanned) <= remaining {
+		return nil
+	}
+
+	allowed := make(map[string]bool, remaining)
+	for _, path := range append(newPlanned, newUnplanned...) {
+		if len(allowed) >= remaining {
+			break
+		}
+		allowed[path] = true
+	}
+	dropped := make(map[string]bool)
+	var droppedPaths []string
+	kept := output.Files[:0]
+	for _, file := range output.Files {
+		path := sanitizeFilePath(file.Path)
+		if _, ok := existing[path]; ok || allowed[path] {
+			kept = append(kept, file)
+			continue
+		}
+		if !dropped[path] {
+			dropped[path] = true
+			droppedPaths = append(droppedPaths, path)
+		}
+	}
+	output.Files = kept
+	if output.Completion != nil {
+		output.Completion.CreatedFiles = filterTrimmedPaths(output.Completion.CreatedFiles, dropped)
+	}
+	if output.Metrics == nil {
+		output.Metrics = map[string]any{}
+	}
+	output.Metrics["file_budget_dropped_count"] = len(droppedPaths)
+	message := fmt.Sprintf("File budget of %d reached: left out %d new file(s): %s", budget, len(droppedPaths), strings.Join(droppedPaths, ", "))
+	output.Messages = append(output.Messages, message)
+
+	build.mu.Lock()
+	if build.Plan != nil {
+		build.Plan.TrimmedFiles = dedupeStrings(append(build.Plan.TrimmedFiles, droppedPaths...))
+	}
+	build.mu.Unlock()
+
+	taskID := ""
+	if task != nil {
+		taskID = task.ID
+	}
+	log.Printf("Build %s: task %s exceeded file budget %d; dropped %v", build.ID, taskID, budget, droppedPaths)
+	am.broadcastScopeTrimmed(build, "generation", budget, droppedPaths, taskID, message)
+	return droppedPaths
+}
+
+func (am *AgentManager) broadcastScopeTrimmed(build *Build, stage string, budget int, trimmed []string, taskID string, message string) {
+	data := map[string]any{
+		"stage":       stage,
+		"file_budget": budget,
+		"trimmed":     trimmed,
+		"message":     message,
+		"user_update": true,
+	}
+	if taskID != "" {
+		data["task_id"] = taskID
+	}
+	am.broadcast(build.ID, &WSMessage{
+		Type:      WSBuildScopeTrimmed,
+		BuildID:   build.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
@@ -0,0 +1,105 @@
+package agents
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestResolveBuildFileBudgetOnlyTightensTheTierCap(t *testing.T) {
+	cases := []struct {
+		plan      string
+		requested int
+		want      int
+	}{
+		{"free", 0, 30},
+		{"pro", 0, 90},
+		{"pro", 200, 90},
+		{"pro", 40, 40},
+		{"builder", 3, minBuildFileBudget},
+		{"unknown", 0, defaultBuildFileBudget},
+	}
+	for _, tc := range cases {
+		if got := resolveBuildFileBudget(tc.plan, tc.requested); got != tc.want {
+			t.Errorf("resolveBuildFileBudget(%q, %d) = %d, want %d", tc.plan, tc.requested, got, tc.want)
+		}
+	}
+}
+
+func TestFitPlanToFileBudgetTrimsLowestPriorityFiles(t *testing.T) {
+	plan := &BuildPlan{
+		ScaffoldFiles: []GeneratedFile{{Path: "package.json"}},
+		Files: []PlannedFile{
+			{Path: "README.md", Type: "docs"},
+			{Path: "package.json", Type: "config"},
+			{Path: "server/routes/api.ts", Type: "backend"},
+			{Path: "src/App.test.tsx", Type: "frontend"},
+			{Path: "src/App.tsx", Type: "frontend"},
+			{Path: "src/components/Extra.tsx", Type: "frontend"},
+		},
+		WorkOrders: []BuildWorkOrder{{
+			Role:          RoleFrontend,
+			OwnedFiles:    []string{"src/App.tsx", "src/App.test.tsx", "src/components/Extra.tsx"},
+			RequiredFiles: []string{"src/App.tsx"},
+		}},
+	}
+
+	trimmed := fitPlanToFileBudget(plan, 4)
+	if want := []string{"README.md", "src/App.test.tsx"}; !reflect.DeepEqual(trimmed, want) {
+		t.Fatalf("trimmed = %v, want tests and docs first: %v", trimmed, want)
+	}
+	if len(plan.Files) != 4 || plan.FileBudget != 4 || !reflect.DeepEqual(plan.TrimmedFiles, trimmed) {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if owned := plan.WorkOrders[0].OwnedFiles; !reflect.DeepEqual(owned, []string{"src/App.tsx", "src/components/Extra.tsx"}) {
+		t.Fatalf("work order still owns trimmed files: %v", owned)
+	}
+
+	if fitPlanToFileBudget(plan, 10) != nil {
+		t.Fatal("plans inside the budget should not be trimmed")
+	}
+}
+
+func TestEnforceBuildFileBudgetKeepsPlannedFilesAndEdits(t *testing.T) {
+	am := &AgentManager{}
+	build := &Build{
+		ID:         "budget",
+		FileBudget: 4,
+		Plan:       &BuildPlan{Files: []PlannedFile{{Path: "src/planned.ts"}}},
+		Tasks: []*Task{{
+			ID:   "done",
+			Type: TaskGenerateFile,
+			Output: &TaskOutput{Files: []GeneratedFile{
+				{Path: "src/a.ts", Content: "a"},
+				{Path: "src/b.ts", Content: "b"},
+			}},
+		}},
+	}
+
+	files := []GeneratedFile{{Path: "src/a.ts", Content: "edited"}}
+	for i := 0; i < 3; i++ {
+		files = append(files, GeneratedFile{Path: fmt.Sprintf("src/extra%d.ts", i), Content: "x"})
+	}
+	files = append(files, GeneratedFile{Path: "src/planned.ts", Content: "p"})
+	output := &TaskOutput{Files: files}
+
+	dropped := am.enforceBuildFileBudget(build, &Task{ID: "next"}, output)
+	if want := []string{"src/extra1.ts", "src/extra2.ts"}; !reflect.DeepEqual(dropped, want) {
+		t.Fatalf("dropped = %v, want %v", dropped, want)
+	}
+	var kept []string
+	for _, file := range output.Files {
+		kept = append(kept, file.Path)
+	}
+	if want := []string{"src/a.ts", "src/extra0.ts", "src/planned.ts"}; !reflect.DeepEqual(kept, want) {
+		t.Fatalf("kept = %v, want the edit, the planned file and one extra", kept)
+	}
+	if !reflect.DeepEqual(build.Plan.TrimmedFiles, dropped) {
+		t.Fatalf("trimmed scope = %v", build.Plan.TrimmedFiles)
+	}
+
+	build.FileBudget = 0
+	if am.enforceBuildFileBudget(build, nil, &TaskOutput{Files: files}) != nil {
+		t.Fatal("builds without a budget are not limited")
+	}
+}
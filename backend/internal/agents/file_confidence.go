@@ -0,0 +1,237 @@
+package agents
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Risk levels for a generated file, from its confidence score.
+const (
+	FileRiskLow    = "low"
+	FileRiskMedium = "medium"
+	FileRiskHigh   = "high"
+)
+
+// Kinds of signal that lower a file's confidence.
+const (
+	FileSignalVerification = "verification" // named in a failing or warning verification report
+	FileSignalLint         = "lint"         // static source checks or coding standards violations
+	FileSignalReviewer     = "reviewer"     // flagged by a review agent
+	FileSignalTruncation   = "truncation"   // output was cut off or still looks truncated
+	FileSignalRepair       = "repair"       // rewritten by automated fix tasks
+)
+
+const (
+	fileConfidenceMediumBelow = 80
+	fileConfidenceHighBelow   = 50
+	maxFileSignalDetail       = 240
+	maxFileRepairPenalty      = 15
+)
+
+// FileConfidence is the post-generation confidence score of one file.
+// Score runs from 0 to 100; lower scores need human attention first.
+type FileConfidence struct {
+	Path    string           `json:"path"`
+	Score   int              `json:"score"`
+	Risk    string           `json:"risk"`
+	Signals []FileRiskSignal `json:"signals,omitempty"`
+}
+
+// FileRiskSignal is one reason a file's score was lowered.
+type FileRiskSignal struct {
+	Kind    string `json:"kind"`
+	Detail  string `json:"detail"`
+	Penalty int    `json:"penalty"`
+}
+
+// FileConfidenceReport scores every file of a build, lowest score first.
+type FileConfidenceReport struct {
+	Files          []FileConfidence `json:"files"`
+	NeedsAttention int              `json:"needs_attention"`
+	AverageScore   int              `json:"average_score"`
+	GeneratedAt    time.Time        `json:"generated_at"`
+}
+
+// scoreFileConfidence runs the scoring pass over a build's final files,
+// records the report on the build snapshot and returns it.
+func (am *AgentManager) scoreFileConfidence(build *Build, files []GeneratedFile) *FileConfidenceReport {
+	if build == nil || len(files) == 0 {
+		return nil
+	}
+	report := computeFileConfidence(build, files, time.Now().UTC())
+
+	build.mu.Lock()
+	build.SnapshotState.FileConfidence = report
+	build.mu.Unlock()
+	return report
+}
+
+func computeFileConfidence(build *Build, files []GeneratedFile, now time.Time) *FileConfidenceReport {
+	build.mu.RLock()
+	var reports []VerificationReport
+	if build.SnapshotState.Orchestration != nil {
+		reports = latestVerificationReports(build.SnapshotState.Orchestration.VerificationReports)
+	}
+	standards := build.SnapshotState.CodingStandards
+	truncated := map[string]bool{}
+	repairs := map[string]int{}
+	var reviewNotes []string
+	for _, task := range build.Tasks {
+		if task == nil || task.Output == nil {
+			continue
+		}
+		for _, path := range task.Output.TruncatedFiles {
+			truncated[sanitizeFilePath(path)] = true
+		}
+		switch task.Type {
+		case TaskFix:
+			for _, f := range task.Output.Files {
+				repairs[sanitizeFilePath(f.Path)]++
+			}
+		case TaskReview:
+			reviewNotes = append(reviewNotes, task.Output.Messages...)
+			reviewNotes = append(reviewNotes, task.Output.Suggestions...)
+		}
+	}
+	build.mu.RUnlock()
+
+	report := &FileConfidenceReport{Files: make([]FileConfidence, 0, len(files)), GeneratedAt: now}
+	total := 0
+	for _, file := range files {
+		path := sanitizeFilePath(file.Path)
+		if path == "" || path == BuildProvenanceFileName {
+			continue
+		}
+		var signals []FileRiskSignal
+		add := func(kind string, penalty int, detail string) {
+			detail = strings.TrimSpace(detail)
+			if len(detail) > maxFileSignalDetail {
+				detail = detail[:maxFileSignalDetail] + "…"
+			}
+			signals = append(signals, FileRiskSignal{Kind: kind, Detail: detail, Penalty: penalty})
+		}
+
+		for _, r := range reports {
+			if r.Status != VerificationPassed {
+				for _, msg := range append(append([]string(nil), r.Errors...), r.Blockers...) {
+					if mentionsFilePath(msg, path) {
+						add(FileSignalVerification, 30, msg)
+					}
+				}
+			}
+			for _, msg := range r.Warnings {
+				if mentionsFilePath(msg, path) {
+					add(FileSignalVerification, 10, msg)
+				}
+			}
+		}
+
+		if hasUnresolvedPatchOrMergeMarkers(file.Content) {
+			add(FileSignalLint, 40, path+" contains unresolved patch/merge markers")
+		}
+		if msg := scaffoldPlaceholderValidationError(path, file.Content); msg != "" {
+			add(FileSignalLint, 25, msg)
+		}
+		for _, msg := range detectSourceArtifactAnomalies(path, file.Content, strings.ToLower(filepath.Ext(path))) {
+			add(FileSignalLint, 25, msg)
+		}
+		if standards != nil {
+			for _, v := range standards.Violations {
+				if sanitizeFilePath(v.Path) != path {
+					continue
+				}
+				penalty := 8
+				if v.Severity == "error" {
+					penalty = 20
+				}
+				add(FileSignalLint, penalty, v.Message)
+			}
+		}
+
+		for _, note := range reviewNotes {
+			if !mentionsFilePath(note, path) {
+				continue
+			}
+			if reviewMessageIndicatesCriticalIssue(note) {
+				add(FileSignalReviewer, 35, note)
+			} else {
+				add(FileSignalReviewer, 10, note)
+			}
+		}
+
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".js", ".jsx", ".ts", ".tsx":
+			if msg := detectLikelyTruncatedJSTSFile(path, file.Content); msg != "" {
+				add(FileSignalTruncation, 40, msg)
+				break
+			}
+			fallthrough
+		default:
+			if truncated[path] {
+				add(FileSignalTruncation, 15, "generation hit the output limit and was continued")
+			}
+		}
+
+		if n := repairs[path]; n > 0 {
+			add(FileSignalRepair, min(5*n, maxFileRepairPenalty), fmt.Sprintf("rewritten by %d automated fix task(s)", n))
+		}
+
+		entry := FileConfidence{Path: path, Score: 100, Signals: signals}
+		for _, s := range signals {
+			entry.Score -= s.Penalty
+		}
+		entry.Score = max(entry.Score, 0)
+		entry.Risk = fileRiskForScore(entry.Score)
+		if entry.Risk != FileRiskLow {
+			report.NeedsAttention++
+		}
+		total += entry.Score
+		report.Files = append(report.Files, entry)
+	}
+	if len(report.Files) > 0 {
+		report.AverageScore = total / len(report.Files)
+	}
+	sort.SliceStable(report.Files, func(i, j int) bool {
+		if report.Files[i].Score != report.Files[j].Score {
+			return report.Files[i].Score < report.Files[j].Score
+		}
+		return report.Files[i].Path < report.Files[j].Path
+	})
+	return report
+}
+
+func fileRiskForScore(score int) string {
+	switch {
+	case score < fileConfidenceHighBelow:
+		return FileRiskHigh
+	case score < fileConfidenceMediumBelow:
+		return FileRiskMedium
+	default:
+		return FileRiskLow
+	}
+}
+
+// mentionsFilePath reports whether text names path as a whole path, so
+// "index.ts" does not match a message about "src/index.ts".
+func mentionsFilePath(text, path string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], path)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(path)
+		if (start == 0 || !isFilePathByte(text[start-1])) && (end == len(text) || !isFilePathByte(text[end]) || text[end] == '.' && (end+1 == len(text) || !isFilePathByte(text[end+1]))) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isFilePathByte(c byte) bool {
+	return c == '/' || c == '.' || c == '_' || c == '-' || c == '@' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"apex-build/internal/codestandards"
+)
+
+func TestComputeFileConfidence(t *testing.T) {
+	build := &Build{
+		ID: "build-1",
+		SnapshotState: BuildSnapshotState{
+			Orchestration: &BuildOrchestrationState{VerificationReports: []VerificationReport{
+				{ID: "r1", Phase: "compile", Status: VerificationFailed, Errors: []string{"src/api.ts(4,2): error TS2304"}},
+				{ID: "r2", Phase: "final", Status: VerificationPassed, Warnings: []string{"server.js listens on a hard-coded port"}},
+			}},
+			CodingStandards: &codestandards.Report{Violations: []codestandards.Violation{
+				{Path: "server.js", Severity: "error", Message: "banned library moment"},
+			}},
+		},
+		Tasks: []*Task{
+			{Type: TaskGenerateAPI, Output: &TaskOutput{TruncatedFiles: []string{"src/api.ts"}}},
+			{Type: TaskFix, Output: &TaskOutput{Files: []GeneratedFile{{Path: "src/api.ts"}}}},
+			{Type: TaskReview, Output: &TaskOutput{Messages: []string{
+				"Critical: SQL injection in src/db.ts query builder",
+				"No critical issues in src/App.tsx",
+			}}},
+		},
+	}
+	files := []GeneratedFile{
+		{Path: "src/api.ts", Content: "export const api = () => 1\n"},
+		{Path: "server.js", Content: "const app = express()\n"},
+		{Path: "src/db.ts", Content: "export const db = 1\n"},
+		{Path: "src/App.tsx", Content: "export default function App() { return null }\n"},
+		{Path: "src/index.ts", Content: "const x =\n"},
+		{Path: "index.ts", Content: "export {}\n"},
+		{Path: BuildProvenanceFileName, Content: "{}"},
+	}
+
+	report := computeFileConfidence(build, files, time.Now())
+	if len(report.Files) != 6 {
+		t.Fatalf("expected every file but the manifest scored, got %d", len(report.Files))
+	}
+	byPath := map[string]FileConfidence{}
+	for _, f := range report.Files {
+		byPath[f.Path] = f
+	}
+
+	if api := byPath["src/api.ts"]; api.Score != 50 || api.Risk != FileRiskMedium || len(api.Signals) != 3 {
+		t.Fatalf("expected verification, truncation and repair signals, got %+v", api)
+	}
+	if server := byPath["server.js"]; server.Score != 70 || server.Risk != FileRiskMedium {
+		t.Fatalf("expected warning and standards signals, got %+v", server)
+	}
+	if db := byPath["src/db.ts"]; db.Score != 65 || db.Signals[0].Kind != FileSignalReviewer {
+		t.Fatalf("expected critical reviewer flag, got %+v", db)
+	}
+	if app := byPath["src/App.tsx"]; app.Score != 90 || app.Risk != FileRiskLow {
+		t.Fatalf("expected only a minor reviewer mention, got %+v", app)
+	}
+	if idx := byPath["src/index.ts"]; idx.Score != 60 || idx.Signals[0].Kind != FileSignalTruncation {
+		t.Fatalf("expected truncation heuristic, got %+v", idx)
+	}
+	if root := byPath["index.ts"]; root.Score != 100 || len(root.Signals) != 0 {
+		t.Fatalf("root index.ts must not match messages about src/index.ts, got %+v", root)
+	}
+	if report.Files[0].Path != "src/api.ts" || report.NeedsAttention != 4 {
+		t.Fatalf("expected lowest score first and 4 files needing attention, got %s/%d", report.Files[0].Path, report.NeedsAttention)
+	}
+}
@@ -0,0 +1,188 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/spend"
+)
+
+const (
+	// maxFileContinuations caps continuation requests per truncated file so a
+	// model that never finishes cannot loop forever.
+	maxFileContinuations = 4
+
+	// maxContinuedFileBytes stops continuing files that have grown past any
+	// plausible generated source size.
+	maxContinuedFileBytes = 512 * 1024
+)
+
+// trackOutputLimitTruncation marks the last generated file as truncated when
+// the provider reports the response stopped at its output token limit. The
+// parser only notices cut-offs inside an open code fence; a file emitted
+// without fences can end mid-content and still parse cleanly.
+func trackOutputLimitTruncation(output *TaskOutput, response *ai.AIResponse) {
+	if output == nil || len(output.Files) == 0 || !ai.HitOutputLimit(response) {
+		return
+	}
+	last := output.Files[len(output.Files)-1].Path
+	for _, path := range output.TruncatedFiles {
+		if path == last {
+			return
+		}
+	}
+	output.TruncatedFiles = append(output.TruncatedFiles, last)
+}
+
+// continueTruncatedFile asks the model to continue target from where it was
+// cut off and stitches each chunk onto the file. It keeps asking while a
+// chunk itself hits the output limit or the file still looks truncated, and
+// stops after maxFileContinuations rounds, when the file reaches
+// maxContinuedFileBytes, or when a chunk is empty, only repeats the file's
+// tail or repeats an earlier chunk. It returns the rounds used and whether
+// the file is complete.
+func (am *AgentManager) continueTruncatedFile(
+	ctx context.Context,
+	task *Task,
+	build *Build,
+	agent *Agent,
+	target *GeneratedFile,
+) (int, bool) {
+	seen := make(map[string]struct{}, maxFileContinuations)
+	for round := 1; round <= maxFileContinuations; round++ {
+		if ctx.Err() != nil {
+			return round - 1, false
+		}
+		if len(target.Content) >= maxContinuedFileBytes {
+			log.Printf("[chunked] %s reached %d bytes; not continuing further", target.Path, len(target.Content))
+			return round - 1, false
+		}
+
+		continuation, hitLimit, ok := am.requestFileContinuation(ctx, task, build, agent, target, round)
+		if !ok {
+			return round, false
+		}
+
+		// Trim overlap — if the model repeated any of the context lines, drop them.
+		continuation = trimLeadingOverlap(target.Content, continuation)
+		chunk := strings.TrimSpace(continuation)
+		if chunk == "" {
+			log.Printf("[chunked] continuation only repeated overlap for %s in task %s", target.Path, task.ID)
+			return round, false
+		}
+		if _, repeated := seen[chunk]; repeated {
+			log.Printf("[chunked] continuation %d for %s repeated an earlier chunk; stopping", round, target.Path)
+			return round, false
+		}
+		seen[chunk] = struct{}{}
+
+		target.Content = target.Content + "\n" + continuation
+		target.Size = int64(len(target.Content))
+		if hitLimit {
+			log.Printf("[chunked] continuation %d for %s hit the output limit; requesting more", round, target.Path)
+			continue
+		}
+		if isLikelyTruncatableJSTSFile(*target) {
+			if truncationErr := detectLikelyTruncatedJSTSFile(target.Path, target.Content); truncationErr != "" {
+				log.Printf("[chunked] continuation %d for %s still appears truncated: %s", round, target.Path, truncationErr)
+				continue
+			}
+		}
+		return round, true
+	}
+	return maxFileContinuations, false
+}
+
+// requestFileContinuation makes one continuation call for target. It returns
+// the continuation text, whether the call itself stopped at the output limit,
+// and false when no usable continuation came back.
+func (am *AgentManager) requestFileContinuation(
+	ctx context.Context,
+	task *Task,
+	build *Build,
+	agent *Agent,
+	target *GeneratedFile,
+	round int,
+) (string, bool, bool) {
+	path := target.Path
+
+	// Use the last OverlapLines as context so the model picks up exactly
+	// where it stopped without repeating already-written code.
+	lines := strings.Split(target.Content, "\n")
+	ctxStart := len(lines) - OverlapLines
+	if ctxStart < 0 {
+		ctxStart = 0
+	}
+	ctxLines := lines[ctxStart:]
+	ctxText := strings.Join(ctxLines, "\n")
+
+	continuationPrompt := fmt.Sprintf(
+		"You were generating the file `%s` but the response was cut off mid-file.\n\n"+
+			"Here are the last %d lines you wrote:\n\n```\n%s\n```\n\n"+
+			"Continue EXACTLY from where the code was cut off. "+
+			"Output ONLY the continuation — do not repeat the lines shown above. "+
+			"No explanation, no markdown fences.",
+		path, len(ctxLines), ctxText,
+	)
+	if round > 1 {
+		continuationPrompt += fmt.Sprintf(" This is continuation %d of at most %d; finish the file if you can.", round, maxFileContinuations)
+	}
+
+	if am.budgetEnforcer != nil {
+		preAuth, preAuthErr := am.budgetEnforcer.PreAuthorizeForProject(build.UserID, build.ProjectID, build.ID, estimatedRequestCostUSDForBuild(build))
+		if preAuthErr == nil && !preAuth.Allowed {
+			log.Printf("[chunked] continuation blocked by budget cap for %s in task %s", path, task.ID)
+			return "", false, false
+		}
+	}
+
+	resp, err := am.aiRouter.Generate(ctx, agent.Provider, continuationPrompt, GenerateOptions{
+		UserID:      build.UserID,
+		BuildID:     build.ID,
+		AgentID:     agent.ID,
+		TaskID:      task.ID,
+		MaxTokens:   8000,
+		Temperature: 0.1,
+		SystemPrompt: "You are completing a source file that was truncated. " +
+			"Output only the remaining code, starting from exactly where the file was cut off.",
+		RoleHint:        string(RoleSolver),
+		PowerMode:       build.PowerMode,
+		UsePlatformKeys: am.buildUsesPlatformKeys(build),
+	})
+	if err != nil {
+		log.Printf("[chunked] continuation failed for %s in task %s: %v", path, task.ID, err)
+		return "", false, false
+	}
+	if resp == nil {
+		log.Printf("[chunked] continuation returned nil response for %s in task %s", path, task.ID)
+		return "", false, false
+	}
+
+	if am.spendTracker != nil && resp.Usage != nil {
+		am.recordBuildSpend(build, agent, spend.RecordSpendInput{
+			UserID:       build.UserID,
+			ProjectID:    build.ProjectID,
+			BuildID:      build.ID,
+			AgentID:      string(RoleSolver),
+			AgentRole:    string(RoleSolver),
+			Provider:     string(actualProviderForAIResponse(resp, agent.Provider)),
+			Model:        ai.GetModelUsed(resp, nil),
+			Capability:   "chunked_continuation",
+			IsBYOK:       !am.buildUsesPlatformKeys(build),
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+			PowerMode:    string(build.PowerMode),
+			Status:       "success",
+		}, task.ID, string(task.Type))
+	}
+
+	continuation := stripCodeFences(strings.TrimSpace(resp.Content))
+	if continuation == "" {
+		log.Printf("[chunked] continuation returned empty content for %s in task %s", path, task.ID)
+		return "", false, false
+	}
+	return continuation, ai.HitOutputLimit(resp), true
+}
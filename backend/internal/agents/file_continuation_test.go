@@ -0,0 +1,87 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"apex-build/internal/ai"
+)
+
+type continuationRouterStub struct {
+	truncationRouterStub
+	responses []*ai.AIResponse
+	calls     int
+}
+
+func (s *continuationRouterStub) Generate(context.Context, ai.AIProvider, string, GenerateOptions) (*ai.AIResponse, error) {
+	s.calls++
+	if len(s.responses) == 0 {
+		return &ai.AIResponse{Content: "x"}, nil
+	}
+	next := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	return next, nil
+}
+
+func cutOff(content string) *ai.AIResponse {
+	return &ai.AIResponse{Content: content, Metadata: map[string]interface{}{"finish_reason": "length"}}
+}
+
+func TestCompleteTruncatedFilesStitchesChunksUntilFinished(t *testing.T) {
+	t.Parallel()
+
+	router := &continuationRouterStub{responses: []*ai.AIResponse{
+		cutOff("  const users = [\n    { id: 1 },"),
+		{Content: "  ]\n  return users\n}\n", Metadata: map[string]interface{}{"finish_reason": "stop"}},
+	}}
+	am := &AgentManager{aiRouter: router}
+
+	out := am.parseTaskOutput(TaskGenerateSchema, "// File: src/seed.ts\n```typescript\nexport function seed() {\n")
+	out.Metrics = map[string]any{}
+	am.completeTruncatedFiles(context.Background(), &Task{ID: "t"}, &Build{UserID: 1}, &Agent{Provider: ai.ProviderClaude}, out)
+
+	if router.calls != 2 || len(out.TruncatedFiles) != 0 {
+		t.Fatalf("calls=%d truncated=%v, want two stitched continuations", router.calls, out.TruncatedFiles)
+	}
+	if got := out.Files[0].Content; !strings.Contains(got, "{ id: 1 },\n]\n  return users") {
+		t.Fatalf("stitched content = %q", got)
+	}
+	if out.Metrics["truncation_continuations"] != 2 {
+		t.Fatalf("metrics = %v", out.Metrics)
+	}
+}
+
+func TestCompleteTruncatedFilesStopsWhenModelLoops(t *testing.T) {
+	t.Parallel()
+
+	router := &continuationRouterStub{responses: []*ai.AIResponse{cutOff("  doWork()\n  doMore()")}}
+	am := &AgentManager{aiRouter: router}
+
+	out := am.parseTaskOutput(TaskGenerateSchema, "// File: src/loop.ts\n```typescript\nexport function run() {\n")
+	am.completeTruncatedFiles(context.Background(), &Task{ID: "t"}, &Build{UserID: 1}, &Agent{Provider: ai.ProviderClaude}, out)
+
+	if router.calls > maxFileContinuations {
+		t.Fatalf("made %d continuation calls, cap is %d", router.calls, maxFileContinuations)
+	}
+	if len(out.TruncatedFiles) != 1 {
+		t.Fatalf("a file that never finishes should stay tracked, got %v", out.TruncatedFiles)
+	}
+}
+
+func TestTrackOutputLimitTruncationMarksLastFile(t *testing.T) {
+	t.Parallel()
+
+	out := &TaskOutput{Files: []GeneratedFile{{Path: "a.sql"}, {Path: "b.sql"}}}
+	trackOutputLimitTruncation(out, &ai.AIResponse{Metadata: map[string]interface{}{"finish_reason": "stop"}})
+	if len(out.TruncatedFiles) != 0 {
+		t.Fatalf("complete responses should not mark files, got %v", out.TruncatedFiles)
+	}
+	trackOutputLimitTruncation(out, &ai.AIResponse{Metadata: map[string]interface{}{"finish_reason": "max_tokens"}})
+	trackOutputLimitTruncation(out, &ai.AIResponse{Metadata: map[string]interface{}{"finish_reason": "max_tokens"}})
+	if len(out.TruncatedFiles) != 1 || out.TruncatedFiles[0] != "b.sql" {
+		t.Fatalf("truncated = %v, want the last file once", out.TruncatedFiles)
+	}
+}
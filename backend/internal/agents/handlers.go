@@ -18,7 +18,9 @@ import (
 
 	"apex-build/internal/ai"
 	"apex-build/internal/applog"
+	"apex-build/internal/envlock"
 	appmiddleware "apex-build/internal/middleware"
+	"apex-build/internal/pagination"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -1223,9 +1225,27 @@ func buildSnapshotStateResponseFields(state BuildSnapshotState, fallbackStatus s
 	if len(state.Blockers) > 0 {
 		fields["blockers"] = append([]BuildBlocker(nil), state.Blockers...)
 	}
+	if state.CodingStandards != nil {
+		fields["coding_standards"] = state.CodingStandards
+	}
+	if state.FileConfidence != nil {
+		fields["file_confidence"] = state.FileConfidence
+	}
+	if state.CostOptimization != nil {
+		fields["cost_optimization"] = state.CostOptimization
+	}
+	if len(state.EnvironmentDrift) > 0 {
+		fields["environment_drift"] = append([]envlock.Drift(nil), state.EnvironmentDrift...)
+	}
+	if state.EnvVarCheck != nil {
+		fields["env_var_check"] = state.EnvVarCheck
+	}
 	if len(state.Approvals) > 0 {
 		fields["approvals"] = append([]BuildApproval(nil), state.Approvals...)
 	}
+	if len(state.AgentTelemetry) > 0 {
+		fields["agent_telemetry"] = state.AgentTelemetry
+	}
 	if orchestration := cloneBuildOrchestrationState(state.Orchestration); orchestration != nil {
 		fields["orchestration"] = orchestration
 		if orchestration.IntentBrief != nil {
@@ -1887,10 +1907,192 @@ func (h *BuildHandler) ResolvePermissionRequest(c *gin.Context) {
 	})
 }
 
-// PauseBuild pauses an active build between phases or queued tasks.
+// GetApprovals returns the build's approval gates and the requests raised at them.
+// GET /api/v1/builds/:buildId/approvals
+func (h *BuildHandler) GetApprovals(c *gin.Context) {
+	buildID := c.Param("buildId")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	if build, err := h.manager.GetBuild(buildID); err == nil {
+		if uid != build.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		gates, requests, approvalsErr := h.manager.GetApprovalRequests(buildID)
+		if approvalsErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approvals"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"gates":    gates,
+			"requests": requests,
+			"live":     true,
+		})
+		return
+	}
+
+	snapshot, err := h.getBuildSnapshot(uid, buildID)
+	if err != nil {
+		writeBuildLookupError(c, err, err)
+		return
+	}
+
+	var gates []BuildApprovalGate
+	if state := parseBuildSnapshotState(snapshot.StateJSON); state.RestoreContext != nil {
+		gates = state.RestoreContext.ApprovalGates
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"gates":    gates,
+		"requests": parseBuildInteraction(snapshot.InteractionJSON).ApprovalRequests,
+		"live":     false,
+	})
+}
+
+// ResolveApproval approves or rejects the build's pending approval request.
+// Approving the plan checkpoint may include an edited plan.
+// POST /api/v1/builds/:buildId/approvals
+func (h *BuildHandler) ResolveApproval(c *gin.Context) {
+	buildID := c.Param("buildId")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	build, err := h.manager.GetBuild(buildID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "build not found"})
+		return
+	}
+	if uid != build.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var req struct {
+		RequestID string     `json:"request_id"`
+		Decision  string     `json:"decision" binding:"required"`
+		Note      string     `json:"note"`
+		Plan      *BuildPlan `json:"plan"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var approve bool
+	switch strings.ToLower(strings.TrimSpace(req.Decision)) {
+	case "approve", "approved":
+		approve = true
+	case "reject", "rejected":
+		approve = false
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision must be approve or reject"})
+		return
+	}
+
+	interaction, resolved, err := h.manager.ResolveApprovalRequest(buildID, req.RequestID, approve, req.Note, req.Plan)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"request":     resolved,
+		"interaction": interaction,
+	})
+}
+
+// GetBuildPlan returns the lead agent's plan as structured data.
+// GET /api/v1/builds/:buildId/plan
+func (h *BuildHandler) GetBuildPlan(c *gin.Context) {
+	buildID := c.Param("buildId")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	build, err := h.manager.GetBuild(buildID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "build not found"})
+		return
+	}
+	if uid != build.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	outline, err := h.manager.GetBuildPlanOutline(buildID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plan": outline})
+}
+
+// EditBuildPlan edits a plan that is waiting at the plan approval gate.
+// PATCH /api/v1/builds/:buildId/plan
+func (h *BuildHandler) EditBuildPlan(c *gin.Context) {
+	buildID := c.Param("buildId")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	build, err := h.manager.GetBuild(buildID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "build not found"})
+		return
+	}
+	if uid != build.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var edit BuildPlanEdit
+	if err := c.ShouldBindJSON(&edit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	build.mu.RLock()
+	editable := pendingPlanApprovalIndexLocked(build) >= 0
+	build.mu.RUnlock()
+	if !editable {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "plan not editable",
+			"details": "The plan can only be edited while the build waits at its plan approval gate",
+		})
+		return
+	}
+
+	outline, err := h.manager.EditBuildPlan(buildID, edit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plan": outline})
+}
+
+// buildActionParam reads the build ID from either the /build/:id or the
+// /builds/:buildId form of a lifecycle route.
+func buildActionParam(c *gin.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	return c.Param("buildId")
+}
+
+// PauseBuild stops dispatching new work for an active build; results from
+// tasks already running are parked until the build resumes.
 // POST /api/v1/build/:id/pause
+// POST /api/v1/builds/:buildId/pause
 func (h *BuildHandler) PauseBuild(c *gin.Context) {
-	buildID := c.Param("id")
+	buildID := buildActionParam(c)
 	uid, ok := appmiddleware.RequireUserID(c)
 	if !ok {
 		return
@@ -1921,10 +2123,11 @@ func (h *BuildHandler) PauseBuild(c *gin.Context) {
 	})
 }
 
-// ResumeBuild resumes a paused build.
+// ResumeBuild applies parked results and re-queues pending work.
 // POST /api/v1/build/:id/resume
+// POST /api/v1/builds/:buildId/resume
 func (h *BuildHandler) ResumeBuild(c *gin.Context) {
-	buildID := c.Param("id")
+	buildID := buildActionParam(c)
 	uid, ok := appmiddleware.RequireUserID(c)
 	if !ok {
 		return
@@ -2301,6 +2504,74 @@ func (h *BuildHandler) GetGeneratedFiles(c *gin.Context) {
 	})
 }
 
+// TranslateBuildLocales machine-translates the build's base locale files into
+// the requested languages and writes them back into the generated artifacts.
+// POST /api/v1/build/:id/i18n/translate
+func (h *BuildHandler) TranslateBuildLocales(c *gin.Context) {
+	buildID := c.Param("id")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	build, err := h.manager.GetBuild(buildID)
+	if err != nil {
+		snapshot, snapErr := h.getBuildSnapshot(uid, buildID)
+		if snapErr != nil {
+			writeBuildLookupError(c, snapErr, err)
+			return
+		}
+		build, _, err = h.manager.restoreBuildSessionFromSnapshot(snapshot)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, buildPlatformIssueResponse(err, "build session unavailable", err.Error()))
+			return
+		}
+	}
+	if uid != build.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+	build.mu.RLock()
+	status := build.Status
+	build.mu.RUnlock()
+	if !isTerminalBuildStatus(status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "build still running",
+			"details": "Locale translation runs after the build finishes.",
+		})
+		return
+	}
+
+	var req I18nTranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	result, err := h.manager.TranslateBuildLocales(c.Request.Context(), build, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if result != nil {
+			status = http.StatusBadGateway
+		}
+		response := gin.H{"error": "translation failed", "details": err.Error()}
+		if result != nil && len(result.Failed) > 0 {
+			response["failed"] = result.Failed
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"build_id":    buildID,
+		"base_locale": result.BaseLocale,
+		"provider":    result.Provider,
+		"files":       result.Files,
+		"failed":      result.Failed,
+		"applied":     result.Applied,
+	})
+}
+
 // GetBuildArtifacts returns the canonical artifact manifest for a build.
 // GET /api/v1/build/:id/artifacts
 func (h *BuildHandler) GetBuildArtifacts(c *gin.Context) {
@@ -2434,6 +2705,12 @@ func (h *BuildHandler) ApplyBuildArtifacts(c *gin.Context) {
 			}
 		}
 
+		if !createdProject {
+			if err := h.manager.snapshotBeforeApply(tx, project.ID, uid, buildID); err != nil {
+				return err
+			}
+		}
+
 		applied, err := applyArtifactManifestTx(tx, &project, uid, manifest, replaceMissing)
 		if err != nil {
 			return err
@@ -2491,10 +2768,11 @@ func (h *BuildHandler) ApplyBuildArtifacts(c *gin.Context) {
 	})
 }
 
-// CancelBuild cancels an in-progress build
+// CancelBuild cancels an in-progress build, preserving its partial artifacts
 // POST /api/v1/build/:id/cancel
+// POST /api/v1/builds/:buildId/cancel
 func (h *BuildHandler) CancelBuild(c *gin.Context) {
-	buildID := c.Param("id")
+	buildID := buildActionParam(c)
 	uid, ok := appmiddleware.RequireUserID(c)
 	if !ok {
 		return
@@ -2540,14 +2818,14 @@ func (h *BuildHandler) ListBuilds(c *gin.Context) {
 	}
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	if page < 1 {
 		page = 1
 	}
-	if limit < 1 || limit > 100 {
-		limit = 20
+	list, err := pagination.Parse(c, buildListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	offset := (page - 1) * limit
 
 	var builds []models.CompletedBuild
 	var total int64
@@ -2559,11 +2837,20 @@ func (h *BuildHandler) ListBuilds(c *gin.Context) {
 		return
 	}
 	if err := retryBuildHistoryRead("list_builds_page", func() error {
-		return h.db.Where("user_id = ?", uid).Order("updated_at DESC").Offset(offset).Limit(limit).Find(&builds).Error
+		return list.Apply(h.db.Where("user_id = ?", uid)).Find(&builds).Error
 	}); err != nil {
 		c.JSON(http.StatusServiceUnavailable, buildPlatformIssueResponse(err, "build history not available", "Build history is temporarily unavailable because the primary database is offline."))
 		return
 	}
+	builds, pageInfo := pagination.Page(list, builds, func(b models.CompletedBuild) (any, any) {
+		switch list.Sort.Key {
+		case "created_at":
+			return b.CreatedAt, b.ID
+		case "total_cost":
+			return b.TotalCost, b.ID
+		}
+		return b.UpdatedAt, b.ID
+	})
 
 	// Convert to response format (exclude raw files JSON, include file count)
 	type BuildSummary struct {
@@ -2632,14 +2919,32 @@ func (h *BuildHandler) ListBuilds(c *gin.Context) {
 		summaries = append(summaries, s)
 	}
 
+	selected, err := pagination.SelectFields(summaries, list.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"builds": summaries,
-		"total":  total,
-		"page":   page,
-		"limit":  limit,
+		"builds":    selected,
+		"total":     total,
+		"page":      page,
+		"limit":     list.Limit,
+		"page_info": pageInfo,
 	})
 }
 
+// buildListSpec is the pagination spec for build history.
+var buildListSpec = pagination.Spec{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "updated_at", Column: "updated_at", Desc: true},
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "total_cost", Column: "total_cost", Desc: true},
+	},
+}
+
 // GetCompletedBuild returns a specific completed build with all file data
 // GET /api/v1/builds/:buildId
 func (h *BuildHandler) GetCompletedBuild(c *gin.Context) {
@@ -3008,6 +3313,45 @@ func (h *BuildHandler) DownloadCompletedBuild(c *gin.Context) {
 	}
 }
 
+// CompareBuilds diffs two saved builds of the same project or description:
+// generated files, spend and validation outcome.
+// GET /api/v1/builds/:buildId/compare/:otherBuildId
+func (h *BuildHandler) CompareBuilds(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, buildPlatformIssueResponse(fmt.Errorf("build history not available"), "build history not available", "Build comparison is temporarily unavailable because the primary database is offline."))
+		return
+	}
+
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	snapshots := make([]models.CompletedBuild, 2)
+	for idx, buildID := range []string{c.Param("buildId"), c.Param("otherBuildId")} {
+		if err := retryBuildHistoryRead("compare_builds", func() error {
+			return h.db.Where("build_id = ? AND user_id = ?", buildID, uid).
+				Order("updated_at DESC").
+				Order("id DESC").
+				First(&snapshots[idx]).Error
+		}); err != nil {
+			if buildPlatformIssueFromError(err) != nil {
+				c.JSON(http.StatusServiceUnavailable, buildPlatformIssueResponse(err, "build history not available", "Build comparison is temporarily unavailable because the primary database is offline."))
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "build not found", "build_id": buildID})
+			return
+		}
+	}
+
+	comparison, err := CompareBuildSnapshots(&snapshots[0], &snapshots[1])
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"comparison": comparison})
+}
+
 // DeleteBuild removes a saved terminal build from build history.
 // DELETE /api/v1/builds/:buildId
 func (h *BuildHandler) DeleteBuild(c *gin.Context) {
@@ -3842,6 +4186,7 @@ func (h *BuildHandler) RegisterRoutes(rg *gin.RouterGroup) {
 		build.GET("/:id/agents", h.GetAgents)
 		build.GET("/:id/tasks", h.GetTasks)
 		build.GET("/:id/files", h.GetGeneratedFiles)
+		build.POST("/:id/i18n/translate", h.TranslateBuildLocales)
 		build.GET("/:id/artifacts", h.GetBuildArtifacts)
 		build.GET("/:id/architecture-references", h.GetBuildArchitectureReferences)
 		build.POST("/:id/apply", h.ApplyBuildArtifacts)
@@ -3869,5 +4214,15 @@ func (h *BuildHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	rg.GET("/builds", h.ListBuilds)
 	rg.GET("/builds/:buildId", h.GetCompletedBuild)
 	rg.GET("/builds/:buildId/download", h.DownloadCompletedBuild)
+	rg.GET("/builds/:buildId/provenance", h.GetBuildProvenance)
+	rg.GET("/builds/:buildId/consensus", h.GetBuildConsensus)
 	rg.DELETE("/builds/:buildId", h.DeleteBuild)
+	rg.GET("/builds/:buildId/approvals", h.GetApprovals)
+	rg.POST("/builds/:buildId/approvals", h.ResolveApproval)
+	rg.GET("/builds/:buildId/plan", h.GetBuildPlan)
+	rg.PATCH("/builds/:buildId/plan", h.EditBuildPlan)
+	rg.POST("/builds/:buildId/pause", h.PauseBuild)
+	rg.POST("/builds/:buildId/resume", h.ResumeBuild)
+	rg.POST("/builds/:buildId/cancel", h.CancelBuild)
+	rg.GET("/builds/:buildId/compare/:otherBuildId", h.CompareBuilds)
 }
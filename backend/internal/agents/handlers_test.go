@@ -74,6 +74,7 @@ func testRouterWithAdmin(am *AgentManager, isAdmin bool) *gin.Engine {
 	rg.GET("/builds/:buildId", h.GetCompletedBuild)
 	rg.GET("/builds/:buildId/download", h.DownloadCompletedBuild)
 	rg.DELETE("/builds/:buildId", h.DeleteBuild)
+	rg.GET("/builds/:buildId/compare/:otherBuildId", h.CompareBuilds)
 	return r
 }
 
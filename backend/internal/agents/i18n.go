@@ -0,0 +1,417 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/spend"
+)
+
+// I18nFramework identifies the translation runtime scaffolded into a generated frontend.
+type I18nFramework string
+
+const (
+	I18nFrameworkI18next   I18nFramework = "i18next"
+	I18nFrameworkReactIntl I18nFramework = "react-intl"
+)
+
+const (
+	defaultI18nBaseLocale     = "en"
+	maxI18nLocales            = 12
+	maxI18nLocaleFileBytes    = 64 * 1024
+	i18nTranslateTimeout      = 90 * time.Second
+	i18nTranslateSystemPrompt = `You are a professional software localization translator.
+You receive a JSON locale file for a web application and translate every string value into the requested language.
+Rules:
+- Return ONLY the translated JSON object. No markdown fences, no commentary.
+- Keep every key and the nesting structure exactly as given. Never add, drop, or rename keys.
+- Preserve interpolation placeholders ({{name}}, {name}, {count, plural, ...}, %s), HTML tags, and markdown verbatim.
+- Keep brand names, product names, URLs, and code identifiers untranslated.
+- Match the tone of the source copy; prefer concise UI wording.`
+)
+
+var i18nLocalePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// BuildI18nOptions asks the Frontend agent to scaffold internationalization
+// support: locale files, a translation framework, and a locale switcher.
+type BuildI18nOptions struct {
+	Enabled    bool          `json:"enabled"`
+	Framework  I18nFramework `json:"framework,omitempty"`
+	BaseLocale string        `json:"base_locale,omitempty"`
+	Locales    []string      `json:"locales,omitempty"`
+}
+
+// normalizeI18nLocale canonicalizes a BCP 47-ish locale tag ("pt_br" -> "pt-BR").
+// Returns "" for values that do not look like a locale.
+func normalizeI18nLocale(raw string) string {
+	raw = strings.ReplaceAll(strings.TrimSpace(raw), "_", "-")
+	if raw == "" {
+		return ""
+	}
+	parts := strings.Split(raw, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	locale := strings.Join(parts, "-")
+	if !i18nLocalePattern.MatchString(locale) {
+		return ""
+	}
+	return locale
+}
+
+// normalizeI18nLocales canonicalizes, dedupes, and caps a locale list while
+// dropping the base locale so callers only see the additional languages.
+func normalizeI18nLocales(raw []string, base string) []string {
+	out := make([]string, 0, len(raw))
+	seen := map[string]bool{base: true}
+	for _, item := range raw {
+		locale := normalizeI18nLocale(item)
+		if locale == "" || seen[locale] {
+			continue
+		}
+		seen[locale] = true
+		out = append(out, locale)
+		if len(out) >= maxI18nLocales {
+			break
+		}
+	}
+	return out
+}
+
+// normalizeBuildI18nOptions returns nil when i18n was not requested so the
+// option never leaks into snapshots or prompts for ordinary builds.
+func normalizeBuildI18nOptions(opts *BuildI18nOptions) *BuildI18nOptions {
+	if opts == nil || !opts.Enabled {
+		return nil
+	}
+	framework := I18nFramework(strings.ToLower(strings.TrimSpace(string(opts.Framework))))
+	switch framework {
+	case I18nFrameworkReactIntl, "react_intl", "reactintl", "formatjs":
+		framework = I18nFrameworkReactIntl
+	default:
+		framework = I18nFrameworkI18next
+	}
+	base := normalizeI18nLocale(opts.BaseLocale)
+	if base == "" {
+		base = defaultI18nBaseLocale
+	}
+	return &BuildI18nOptions{
+		Enabled:    true,
+		Framework:  framework,
+		BaseLocale: base,
+		Locales:    normalizeI18nLocales(opts.Locales, base),
+	}
+}
+
+func cloneBuildI18nOptions(opts *BuildI18nOptions) *BuildI18nOptions {
+	if opts == nil {
+		return nil
+	}
+	clone := *opts
+	clone.Locales = append([]string(nil), opts.Locales...)
+	return &clone
+}
+
+// i18nPromptContext renders the scaffolding contract for agents that write UI
+// code. Backend/database agents never see it; their output is locale-agnostic.
+func i18nPromptContext(build *Build, agent *Agent) string {
+	if build == nil || build.I18n == nil || agent == nil {
+		return ""
+	}
+	switch agent.Role {
+	case RoleFrontend, RoleArchitect, RoleReviewer, RoleSolver:
+	default:
+		return ""
+	}
+	opts := build.I18n
+	allLocales := append([]string{opts.BaseLocale}, opts.Locales...)
+
+	var sb strings.Builder
+	sb.WriteString("\n<i18n_requirements>\n")
+	sb.WriteString("The user enabled internationalization for this app. Scaffold it from the first pass:\n")
+	switch opts.Framework {
+	case I18nFrameworkReactIntl:
+		sb.WriteString("- Translation framework: react-intl. Add \"react-intl\" to frontend dependencies.\n")
+		sb.WriteString("- Create src/i18n/index.ts that loads the locale messages and exports them keyed by locale.\n")
+		sb.WriteString("- Wrap the app in <IntlProvider locale={locale} messages={messages[locale]} defaultLocale=\"" + opts.BaseLocale + "\"> in src/main.tsx.\n")
+		sb.WriteString("- Render every user-facing string through <FormattedMessage id=... /> or intl.formatMessage; use ICU placeholders ({name}).\n")
+	default:
+		sb.WriteString("- Translation framework: i18next with react-i18next. Add \"i18next\" and \"react-i18next\" to frontend dependencies.\n")
+		sb.WriteString("- Create src/i18n/index.ts that calls i18n.use(initReactI18next).init({ resources, lng, fallbackLng: \"" + opts.BaseLocale + "\", interpolation: { escapeValue: false } }) and import it once from src/main.tsx.\n")
+		sb.WriteString("- Render every user-facing string through the useTranslation() hook (t(\"key\")); use {{name}} placeholders.\n")
+	}
+	fmt.Fprintf(&sb, "- Locale files: src/locales/<locale>/translation.json, flat or nested JSON objects of string values. Base locale: %s.\n", opts.BaseLocale)
+	fmt.Fprintf(&sb, "- Write the COMPLETE base locale file (src/locales/%s/translation.json) containing every key the UI uses. Never hard-code UI copy in components.\n", opts.BaseLocale)
+	if len(opts.Locales) > 0 {
+		fmt.Fprintf(&sb, "- Also create src/locales/<locale>/translation.json for: %s. Copy the base keys; values may stay in the base language because a machine-translation pass runs after the build.\n", strings.Join(opts.Locales, ", "))
+	}
+	fmt.Fprintf(&sb, "- Add a LocaleSwitcher component in the main header listing %s, persisting the choice to localStorage (key \"locale\") and defaulting to the browser language when supported.\n", strings.Join(allLocales, ", "))
+	sb.WriteString("- Set <html lang> when the locale changes; format dates and numbers with Intl APIs using the active locale.\n")
+	sb.WriteString("</i18n_requirements>\n")
+	return sb.String()
+}
+
+// findI18nBaseLocaleFiles returns the JSON message files for the base locale.
+// Both directory-per-locale (locales/en/translation.json) and
+// file-per-locale (locales/en.json) layouts are recognized.
+func findI18nBaseLocaleFiles(files []GeneratedFile, base string) []GeneratedFile {
+	out := make([]GeneratedFile, 0, 2)
+	for _, file := range files {
+		if localeFileLocale(file.Path) == base {
+			out = append(out, file)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// localeFileLocale reports which locale a JSON message file belongs to, or "".
+func localeFileLocale(filePath string) string {
+	p := strings.TrimPrefix(sanitizeFilePath(filePath), "./")
+	if !strings.HasSuffix(strings.ToLower(p), ".json") || strings.Contains(p, "node_modules/") {
+		return ""
+	}
+	segments := strings.Split(p, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		switch strings.ToLower(segments[i]) {
+		case "locales", "locale", "i18n", "lang", "translations", "messages":
+		default:
+			continue
+		}
+		if i == len(segments)-2 {
+			return normalizeI18nLocale(strings.TrimSuffix(segments[i+1], path.Ext(segments[i+1])))
+		}
+		return normalizeI18nLocale(segments[i+1])
+	}
+	return ""
+}
+
+// localeFilePathFor maps a base locale file path to the target locale's path.
+func localeFilePathFor(basePath, base, target string) string {
+	p := sanitizeFilePath(basePath)
+	segments := strings.Split(p, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if i == len(segments)-1 {
+			ext := path.Ext(seg)
+			if normalizeI18nLocale(strings.TrimSuffix(seg, ext)) == base {
+				segments[i] = target + ext
+				return strings.Join(segments, "/")
+			}
+			continue
+		}
+		if normalizeI18nLocale(seg) == base {
+			segments[i] = target
+			return strings.Join(segments, "/")
+		}
+	}
+	return ""
+}
+
+// I18nTranslateRequest is the body of POST /build/:id/i18n/translate.
+type I18nTranslateRequest struct {
+	BaseLocale    string   `json:"base_locale,omitempty"`
+	TargetLocales []string `json:"target_locales"`
+}
+
+// I18nTranslationResult summarizes a machine-translation pass.
+type I18nTranslationResult struct {
+	BaseLocale string          `json:"base_locale"`
+	Provider   string          `json:"provider,omitempty"`
+	Files      []GeneratedFile `json:"files"`
+	Failed     []string        `json:"failed,omitempty"`
+	Applied    bool            `json:"applied"`
+}
+
+func (am *AgentManager) selectI18nTranslationProvider(build *Build) ai.AIProvider {
+	if am == nil || am.aiRouter == nil || build == nil || !am.aiRouter.HasConfiguredProviders() {
+		return ""
+	}
+	providers := am.getCurrentlyAvailableProvidersForBuild(build)
+	if len(providers) == 0 {
+		return ""
+	}
+	available := make(map[ai.AIProvider]bool, len(providers))
+	for _, p := range providers {
+		available[p] = true
+	}
+	for _, provider := range rankedProvidersForTaskShapeWithCost(TaskShapeFrontendPatch, am.providerScorecardsForBuild(build, providers), buildCostSensitivity(build)) {
+		if available[provider] {
+			return provider
+		}
+	}
+	return providers[0]
+}
+
+// TranslateBuildLocales machine-translates the base locale message files of a
+// build into each target locale via the AI router and writes the results back
+// into the build's generated artifacts.
+func (am *AgentManager) TranslateBuildLocales(ctx context.Context, build *Build, req I18nTranslateRequest) (*I18nTranslationResult, error) {
+	if build == nil {
+		return nil, fmt.Errorf("build is required")
+	}
+	base := normalizeI18nLocale(req.BaseLocale)
+	build.mu.RLock()
+	if base == "" && build.I18n != nil {
+		base = build.I18n.BaseLocale
+	}
+	files := am.collectGeneratedFiles(build)
+	build.mu.RUnlock()
+	if base == "" {
+		base = defaultI18nBaseLocale
+	}
+	targets := normalizeI18nLocales(req.TargetLocales, base)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("at least one valid target locale is required")
+	}
+	sources := findI18nBaseLocaleFiles(files, base)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no %s locale files found (expected e.g. src/locales/%s/translation.json)", base, base)
+	}
+	provider := am.selectI18nTranslationProvider(build)
+	if provider == "" {
+		return nil, fmt.Errorf("no AI provider available for translation")
+	}
+
+	result := &I18nTranslationResult{BaseLocale: base, Provider: string(provider), Files: []GeneratedFile{}}
+	for _, target := range targets {
+		for _, source := range sources {
+			targetPath := localeFilePathFor(source.Path, base, target)
+			if targetPath == "" {
+				continue
+			}
+			content, err := am.translateLocaleFile(ctx, build, provider, source, base, target)
+			if err != nil {
+				log.Printf("[i18n] build %s: translate %s -> %s failed: %v", build.ID, source.Path, target, err)
+				result.Failed = append(result.Failed, fmt.Sprintf("%s (%s): %v", targetPath, target, err))
+				continue
+			}
+			result.Files = append(result.Files, GeneratedFile{
+				Path:     targetPath,
+				Content:  content,
+				Language: "json",
+				Size:     int64(len(content)),
+				IsNew:    true,
+			})
+		}
+	}
+	if len(result.Files) == 0 {
+		return result, fmt.Errorf("translation failed for every requested locale")
+	}
+	result.Applied = am.cvApplyTaskOutputToBuild(build, &TaskOutput{Files: result.Files})
+	if result.Applied {
+		am.persistBuildSnapshot(build, nil)
+	}
+	return result, nil
+}
+
+func (am *AgentManager) translateLocaleFile(ctx context.Context, build *Build, provider ai.AIProvider, source GeneratedFile, base, target string) (string, error) {
+	if len(source.Content) > maxI18nLocaleFileBytes {
+		return "", fmt.Errorf("locale file exceeds %d bytes", maxI18nLocaleFileBytes)
+	}
+	var sourceMessages map[string]any
+	if err := json.Unmarshal([]byte(source.Content), &sourceMessages); err != nil {
+		return "", fmt.Errorf("base locale file is not a JSON object: %w", err)
+	}
+
+	translateCtx, cancel := context.WithTimeout(ctx, i18nTranslateTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf("Translate this %s locale file into %s (%s).\n\n%s", base, target, source.Path, source.Content)
+	resp, err := am.aiRouter.Generate(translateCtx, provider, prompt, GenerateOptions{
+		UserID:          build.UserID,
+		BuildID:         build.ID,
+		MaxTokens:       8000,
+		Temperature:     0.2,
+		SystemPrompt:    i18nTranslateSystemPrompt,
+		RoleHint:        string(RoleFrontend),
+		PowerMode:       PowerFast,
+		UsePlatformKeys: build.ProviderMode != "byok",
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp == nil || strings.TrimSpace(resp.Content) == "" {
+		return "", fmt.Errorf("empty translation response")
+	}
+	if am.spendTracker != nil && resp.Usage != nil {
+		am.recordBuildSpend(build, nil, spend.RecordSpendInput{
+			UserID:       build.UserID,
+			ProjectID:    build.ProjectID,
+			BuildID:      build.ID,
+			AgentID:      "i18n-translator",
+			AgentRole:    string(RoleFrontend),
+			Provider:     string(actualProviderForAIResponse(resp, provider)),
+			Model:        ai.GetModelUsed(resp, nil),
+			Capability:   "i18n_translate",
+			IsBYOK:       build.ProviderMode == "byok",
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+			PowerMode:    string(PowerFast),
+			Status:       "success",
+		}, "", "i18n_translate")
+	}
+	return parseTranslatedLocaleJSON(resp.Content, sourceMessages)
+}
+
+// parseTranslatedLocaleJSON validates a model response against the source
+// messages: the key set must match exactly so a dropped or invented key never
+// reaches the app as a missing-translation fallback.
+func parseTranslatedLocaleJSON(raw string, sourceMessages map[string]any) (string, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+	raw = strings.TrimSpace(raw)
+	if start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}"); start >= 0 && end > start {
+		raw = raw[start : end+1]
+	}
+	var translated map[string]any
+	if err := json.Unmarshal([]byte(raw), &translated); err != nil {
+		return "", fmt.Errorf("translation is not valid JSON: %w", err)
+	}
+	want := flattenLocaleKeys("", sourceMessages, nil)
+	got := flattenLocaleKeys("", translated, nil)
+	if len(want) != len(got) {
+		return "", fmt.Errorf("translation has %d keys, source has %d", len(got), len(want))
+	}
+	for key := range want {
+		if !got[key] {
+			return "", fmt.Errorf("translation is missing key %q", key)
+		}
+	}
+	out, err := json.MarshalIndent(translated, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+func flattenLocaleKeys(prefix string, messages map[string]any, keys map[string]bool) map[string]bool {
+	if keys == nil {
+		keys = map[string]bool{}
+	}
+	for key, value := range messages {
+		full := key
+		if prefix != "" {
+			full = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flattenLocaleKeys(full, nested, keys)
+			continue
+		}
+		keys[full] = true
+	}
+	return keys
+}
@@ -0,0 +1,141 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"apex-build/internal/ai"
+)
+
+func TestNormalizeBuildI18nOptions(t *testing.T) {
+	if got := normalizeBuildI18nOptions(&BuildI18nOptions{Locales: []string{"fr"}}); got != nil {
+		t.Fatalf("expected disabled options to normalize to nil, got %+v", got)
+	}
+
+	got := normalizeBuildI18nOptions(&BuildI18nOptions{
+		Enabled:   true,
+		Framework: "React_Intl",
+		Locales:   []string{"pt_br", "EN", "fr", "fr", "not a locale", ""},
+	})
+	if got == nil {
+		t.Fatal("expected normalized options")
+	}
+	if got.Framework != I18nFrameworkReactIntl {
+		t.Fatalf("framework = %q, want react-intl", got.Framework)
+	}
+	if got.BaseLocale != "en" {
+		t.Fatalf("base locale = %q, want en", got.BaseLocale)
+	}
+	if strings.Join(got.Locales, ",") != "pt-BR,fr" {
+		t.Fatalf("locales = %v, want [pt-BR fr]", got.Locales)
+	}
+}
+
+func TestI18nPromptContextTargetsUIAgentsOnly(t *testing.T) {
+	build := &Build{I18n: normalizeBuildI18nOptions(&BuildI18nOptions{Enabled: true, Locales: []string{"de"}})}
+
+	frontend := i18nPromptContext(build, &Agent{Role: RoleFrontend})
+	for _, want := range []string{"<i18n_requirements>", "react-i18next", "src/locales/en/translation.json", "LocaleSwitcher", "de"} {
+		if !strings.Contains(frontend, want) {
+			t.Fatalf("frontend i18n context missing %q:\n%s", want, frontend)
+		}
+	}
+	if got := i18nPromptContext(build, &Agent{Role: RoleBackend}); got != "" {
+		t.Fatalf("backend agents should not receive i18n context, got %q", got)
+	}
+	if got := i18nPromptContext(&Build{}, &Agent{Role: RoleFrontend}); got != "" {
+		t.Fatalf("builds without i18n should not receive context, got %q", got)
+	}
+}
+
+func TestLocaleFilePathMapping(t *testing.T) {
+	cases := []struct {
+		path   string
+		locale string
+		target string
+	}{
+		{path: "src/locales/en/translation.json", locale: "en", target: "src/locales/fr/translation.json"},
+		{path: "frontend/src/i18n/en.json", locale: "en", target: "frontend/src/i18n/fr.json"},
+		{path: "src/components/en.json", locale: "", target: ""},
+		{path: "src/locales/en/README.md", locale: "", target: ""},
+	}
+	for _, tc := range cases {
+		if got := localeFileLocale(tc.path); got != tc.locale {
+			t.Fatalf("localeFileLocale(%q) = %q, want %q", tc.path, got, tc.locale)
+		}
+		if tc.locale == "" {
+			continue
+		}
+		if got := localeFilePathFor(tc.path, "en", "fr"); got != tc.target {
+			t.Fatalf("localeFilePathFor(%q) = %q, want %q", tc.path, got, tc.target)
+		}
+	}
+}
+
+func TestParseTranslatedLocaleJSONRequiresMatchingKeys(t *testing.T) {
+	source := map[string]any{"nav": map[string]any{"home": "Home"}, "greeting": "Hello {{name}}"}
+
+	out, err := parseTranslatedLocaleJSON("```json\n{\"nav\":{\"home\":\"Accueil\"},\"greeting\":\"Bonjour {{name}}\"}\n```", source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "Accueil") || !strings.HasSuffix(out, "\n") {
+		t.Fatalf("unexpected translated output: %q", out)
+	}
+
+	if _, err := parseTranslatedLocaleJSON(`{"nav":{"home":"Accueil"}}`, source); err == nil {
+		t.Fatal("expected missing key to be rejected")
+	}
+	if _, err := parseTranslatedLocaleJSON(`{"nav":{"home":"Accueil"},"salutation":"Bonjour"}`, source); err == nil {
+		t.Fatal("expected renamed key to be rejected")
+	}
+}
+
+func TestTranslateBuildLocalesWritesTargetFiles(t *testing.T) {
+	router := &stubPreflight{
+		configured:     true,
+		userProviders:  []ai.AIProvider{ai.ProviderClaude},
+		generateResult: &ai.AIResponse{Content: `{"title":"Tableau de bord"}`},
+	}
+	am := &AgentManager{aiRouter: router}
+	build := &Build{
+		ID:           "build-i18n",
+		UserID:       7,
+		ProviderMode: "byok",
+		Status:       BuildCompleted,
+		Tasks: []*Task{{
+			ID:     "ui",
+			Type:   TaskGenerateUI,
+			Status: TaskCompleted,
+			Output: &TaskOutput{Files: []GeneratedFile{
+				{Path: "src/locales/en/translation.json", Content: `{"title":"Dashboard"}`},
+				{Path: "src/App.tsx", Content: "export default function App() { return null }"},
+			}},
+		}},
+	}
+
+	result, err := am.TranslateBuildLocales(context.Background(), build, I18nTranslateRequest{TargetLocales: []string{"fr", "en"}})
+	if err != nil {
+		t.Fatalf("TranslateBuildLocales: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Path != "src/locales/fr/translation.json" {
+		t.Fatalf("expected one fr locale file, got %+v", result.Files)
+	}
+	if !result.Applied {
+		t.Fatal("expected translated file to be written into the build")
+	}
+	if calls := router.generateCalls.Load(); calls != 1 {
+		t.Fatalf("expected one translation call, got %d", calls)
+	}
+
+	var found bool
+	for _, file := range am.collectGeneratedFiles(build) {
+		if file.Path == "src/locales/fr/translation.json" && strings.Contains(file.Content, "Tableau de bord") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected fr locale file in generated files")
+	}
+}
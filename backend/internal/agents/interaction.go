@@ -150,6 +150,9 @@ func copyBuildInteractionStateLocked(build *Build) BuildInteractionState {
 	copyApprovalEvents := make([]BuildApprovalEvent, len(build.Interaction.ApprovalEvents))
 	copy(copyApprovalEvents, build.Interaction.ApprovalEvents)
 
+	copyApprovalRequests := make([]BuildApprovalRequest, len(build.Interaction.ApprovalRequests))
+	copy(copyApprovalRequests, build.Interaction.ApprovalRequests)
+
 	return BuildInteractionState{
 		Messages:           copyMessages,
 		SteeringNotes:      copyNotes,
@@ -158,9 +161,11 @@ func copyBuildInteractionStateLocked(build *Build) BuildInteractionState {
 		WaitingForUser:     build.Interaction.WaitingForUser,
 		Paused:             build.Interaction.Paused,
 		PauseReason:        build.Interaction.PauseReason,
+		ParkedResults:      build.Interaction.ParkedResults,
 		PermissionRules:    copyRules,
 		PermissionRequests: copyRequests,
 		ApprovalEvents:     copyApprovalEvents,
+		ApprovalRequests:   copyApprovalRequests,
 		AttentionRequired:  build.Interaction.AttentionRequired,
 	}
 }
@@ -325,7 +330,7 @@ func resolveWaitingStateLocked(build *Build) {
 	if strings.TrimSpace(build.Interaction.PendingQuestion) != "" {
 		build.Interaction.WaitingForUser = true
 	} else {
-		build.Interaction.WaitingForUser = hasPendingBlockingPermissionRequestLocked(build)
+		build.Interaction.WaitingForUser = hasPendingBlockingPermissionRequestLocked(build) || pendingApprovalRequestIndexLocked(build, "") >= 0
 	}
 	refreshInteractionAttentionLocked(build)
 }
@@ -680,6 +685,29 @@ func (am *AgentManager) PauseBuild(buildID string, reason string) (BuildInteract
 	return interaction, nil
 }
 
+// parkTaskResultIfPaused holds a task result that arrives while the build is
+// paused so follow-up work is not dispatched until the build resumes. A result
+// redelivered by the queue watchdog is parked only once.
+func parkTaskResultIfPaused(build *Build, result *TaskResult) (BuildInteractionState, bool) {
+	if build == nil || result == nil {
+		return BuildInteractionState{}, false
+	}
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	if !build.Interaction.Paused {
+		return BuildInteractionState{}, false
+	}
+	for _, existing := range build.ParkedTaskResults {
+		if existing.TaskID == result.TaskID && existing.Attempt == result.Attempt {
+			return copyBuildInteractionStateLocked(build), true
+		}
+	}
+	build.ParkedTaskResults = append(build.ParkedTaskResults, result)
+	build.Interaction.ParkedResults = len(build.ParkedTaskResults)
+	build.UpdatedAt = time.Now().UTC()
+	return copyBuildInteractionStateLocked(build), true
+}
+
 func (am *AgentManager) ResumeBuild(buildID string, reason string) (BuildInteractionState, error) {
 	build, err := am.GetBuild(buildID)
 	if err != nil {
@@ -707,10 +735,23 @@ func (am *AgentManager) ResumeBuild(buildID string, reason string) (BuildInterac
 		Timestamp: now,
 	})
 	resolveWaitingStateLocked(build)
+	parked := build.ParkedTaskResults
+	build.ParkedTaskResults = nil
+	build.Interaction.ParkedResults = 0
 	interaction := copyBuildInteractionStateLocked(build)
 	build.mu.Unlock()
 
-	am.resumeBuildExecution(build, true)
+	if len(parked) == 0 {
+		am.resumeBuildExecution(build, true)
+	} else {
+		// Apply parked results before re-queueing so their tasks are not run again.
+		go func() {
+			for _, result := range parked {
+				am.processResultSafely(result)
+			}
+			am.resumeBuildExecution(build, true)
+		}()
+	}
 	am.persistBuildSnapshot(build, nil)
 	am.broadcast(buildID, &WSMessage{
 		Type:      WSBuildFSMResumed,
@@ -210,3 +210,66 @@ func TestBuildInteractionPromptContextFiltersTargetedMessagesByAgent(t *testing.
 		t.Fatalf("expected lead to see all targeted directives, got %q", leadContext)
 	}
 }
+
+func TestPausedBuildParksResultsAndCancelPreservesState(t *testing.T) {
+	task := &Task{ID: "task-parked", Type: TaskGenerateUI, Status: TaskInProgress, AssignedTo: "agent-parked"}
+	pending := &Task{ID: "task-pending", Type: TaskGenerateAPI, Status: TaskPending}
+	agent := &Agent{ID: "agent-parked", Role: RoleFrontend, Status: StatusWorking, BuildID: "build-parked", CurrentTask: task}
+	build := &Build{
+		ID:     "build-parked",
+		UserID: 1,
+		Status: BuildInProgress,
+		Tasks:  []*Task{task, pending},
+		Agents: map[string]*Agent{agent.ID: agent},
+	}
+	am := &AgentManager{
+		agents:      map[string]*Agent{agent.ID: agent},
+		builds:      map[string]*Build{build.ID: build},
+		subscribers: map[string][]chan *WSMessage{},
+	}
+	ch := make(chan *WSMessage, 32)
+	am.Subscribe(build.ID, ch)
+
+	if _, err := am.PauseBuild(build.ID, "checking the plan"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	result := &TaskResult{
+		TaskID:  task.ID,
+		AgentID: agent.ID,
+		Success: true,
+		Output:  &TaskOutput{Files: []GeneratedFile{{Path: "src/App.tsx", Content: "export default function App() { return null }"}}},
+	}
+	am.processResult(result)
+	am.processResult(cloneTaskResultForAsync(result)) // watchdog redelivery
+
+	build.mu.RLock()
+	parked, parkedCount, taskStatus := len(build.ParkedTaskResults), build.Interaction.ParkedResults, task.Status
+	build.mu.RUnlock()
+	if parked != 1 || parkedCount != 1 {
+		t.Fatalf("expected one parked result, got %d (count %d)", parked, parkedCount)
+	}
+	if taskStatus != TaskInProgress {
+		t.Fatalf("expected the parked task to stay in progress, got %s", taskStatus)
+	}
+
+	if err := am.CancelBuild(build.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	msg := waitForWSMessage(t, ch, WSBuildFSMCancelled)
+	if cancelled := msg.Data.(map[string]any)["cancelled_tasks"]; cancelled != float64(2) {
+		t.Fatalf("expected both outstanding tasks cancelled, got %v", cancelled)
+	}
+
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+	if build.Status != BuildCancelled || build.ParkedTaskResults != nil || build.Interaction.Paused {
+		t.Fatalf("unexpected build state after cancel: status=%s parked=%d paused=%v", build.Status, len(build.ParkedTaskResults), build.Interaction.Paused)
+	}
+	if task.Status != TaskCancelled || pending.Status != TaskCancelled {
+		t.Fatalf("expected tasks cancelled, got %s / %s", task.Status, pending.Status)
+	}
+	if len(build.Checkpoints) != 1 || build.Checkpoints[0].Name != "Build Cancelled" {
+		t.Fatalf("expected a cancellation checkpoint, got %+v", build.Checkpoints)
+	}
+}
@@ -29,6 +29,8 @@ import (
 	"apex-build/internal/ai"
 	"apex-build/internal/applog"
 	"apex-build/internal/budget"
+	"apex-build/internal/buildhooks"
+	"apex-build/internal/codestandards"
 	"apex-build/internal/metrics"
 	"apex-build/internal/mobile"
 	"apex-build/internal/spend"
@@ -68,12 +70,6 @@ const (
 	decisionAbort          consensusDecision = "abort"
 )
 
-type providerVote struct {
-	Provider  ai.AIProvider
-	Decision  consensusDecision
-	Rationale string
-}
-
 func estimatedRequestCostUSDForBuild(build *Build) float64 {
 	if build == nil {
 		return defaultEstimatedRequestCostUSD
@@ -84,7 +80,7 @@ func estimatedRequestCostUSDForBuild(build *Build) float64 {
 		return 0.15
 	case PowerBalanced:
 		return 0.06
-	case PowerFast:
+	case PowerFast, PowerBudget:
 		return 0.02
 	}
 
@@ -206,6 +202,8 @@ func userFacingTaskLabel(task *Task) string {
 		return "repair patches for failed verification"
 	case TaskDeploy:
 		return "deployment configuration"
+	case TaskDockerize:
+		return "production Dockerfile and image build"
 	default:
 		if label := buildAgentHeartbeatLabel(task); label != "" {
 			return label
@@ -541,10 +539,17 @@ type AgentManager struct {
 	pathGuard              *PathGuard
 	spendTracker           *spend.SpendTracker
 	budgetEnforcer         *budget.BudgetEnforcer
-	errorAnalyzer          *ErrorAnalyzer       // LLM-powered build error analysis (falls back to heuristics if AI unavailable)
-	ctxSelector            *ContextSelector     // smart file context selection for LLM prompts
-	chunkedEditor          *ChunkedEditor       // splits/reassembles large-file edits to stay within output token limits
-	previewVerifier        BuildPreviewVerifier // optional preview readiness verifier (wired in main.go)
+	errorAnalyzer          *ErrorAnalyzer            // LLM-powered build error analysis (falls back to heuristics if AI unavailable)
+	ctxSelector            *ContextSelector          // smart file context selection for LLM prompts
+	chunkedEditor          *ChunkedEditor            // splits/reassembles large-file edits to stay within output token limits
+	previewVerifier        BuildPreviewVerifier      // optional preview readiness verifier (wired in main.go)
+	agentProfileSource     AgentProfileSource        // optional installed agent profiles (wired in main.go)
+	projectInstructions    ProjectInstructionsSource // optional apex.md / org instructions (wired in main.go)
+	codingStandards        CodingStandardsSource     // optional org coding standards (wired in main.go)
+	buildActivity          BuildActivitySink         // optional project activity feed (wired in main.go)
+	restorePoints          RestorePointSnapshotter   // optional project restore points (wired in main.go)
+	outcomeRecorder        BuildOutcomeRecorder      // optional failure-cause records for terminal builds
+	buildHooks             BuildHookRunner           // optional apex.yaml pre_build/post_build hooks (wired in main.go)
 	visionIntake           *VisionIntakeProcessor
 	promptEvolution        *PromptEvolutionStore
 	taskCancels            map[string]context.CancelFunc
@@ -568,6 +573,8 @@ type AIRouter interface {
 type GenerateOptions struct {
 	UserID        uint
 	BuildID       string // For pipeline telemetry correlation.
+	AgentID       string // Attributes live telemetry to an agent; RoleHint is used when empty.
+	TaskID        string
 	RequestID     string
 	OperationID   string
 	MaxTokens     int
@@ -637,6 +644,20 @@ func NewAgentManager(aiRouter AIRouter, db ...*gorm.DB) *AgentManager {
 		am.promptEvolution = NewPromptEvolutionStore(db[0])
 	}
 
+	if source, ok := aiRouter.(interface{ SetTelemetrySink(func(AICallTelemetry)) }); ok {
+		source.SetTelemetrySink(am.RecordAICallTelemetry)
+	}
+	if source, ok := aiRouter.(interface {
+		OnProviderCircuitChange(func(ai.ProviderCircuitEvent))
+	}); ok {
+		source.OnProviderCircuitChange(am.handleProviderCircuitEvent)
+	}
+	if source, ok := aiRouter.(interface {
+		SetRetryBudgetSource(func(string) *ai.RetryBudget)
+	}); ok {
+		source.SetRetryBudgetSource(am.BuildRetryBudget)
+	}
+
 	am.ensureWorkerInfrastructure()
 
 	log.Println("Agent Manager initialized")
@@ -728,6 +749,11 @@ func (am *AgentManager) CreateBuild(userID uint, subscriptionPlan string, req *B
 		return nil, fmt.Errorf("build request is required")
 	}
 	req = am.prepareBuildRequestForCreation(req)
+	// Installed profiles and org standards come from the database; load them
+	// before taking am.mu.
+	agentProfiles := am.resolveBuildAgentProfiles(userID, req.AgentProfiles)
+	codingStandards := am.resolveBuildCodingStandards(userID)
+	byokModelPreferences := am.resolveBuildBYOKModelPreferences(userID)
 
 	am.mu.Lock()
 	defer am.mu.Unlock()
@@ -751,6 +777,9 @@ func (am *AgentManager) CreateBuild(userID uint, subscriptionPlan string, req *B
 	} else if providerMode != "byok" {
 		providerMode = "platform"
 	}
+	if providerMode != "byok" {
+		byokModelPreferences = nil
+	}
 	effectiveDescription := strings.TrimSpace(firstNonEmptyString(req.Prompt, req.Description))
 	if effectiveDescription == "" {
 		effectiveDescription = strings.TrimSpace(req.Description)
@@ -786,6 +815,11 @@ func (am *AgentManager) CreateBuild(userID uint, subscriptionPlan string, req *B
 		MobileReleaseLevel:     mobileReleaseLevel,
 		MobileCapabilities:     mobileCapabilities,
 		MobileAppSpec:          req.MobileAppSpec,
+		I18n:                   normalizeBuildI18nOptions(req.I18n),
+		ApprovalGates:          normalizeBuildApprovalGates(req.ApprovalGates),
+		AgentProfiles:          agentProfiles,
+		CodingStandards:        codingStandards,
+		ModelPreferences:       byokModelPreferences,
 		RoleAssignments:        roleAssignments,
 		ProviderModelOverrides: providerModelOverrides,
 		Agents:                 make(map[string]*Agent),
@@ -817,6 +851,7 @@ func (am *AgentManager) CreateBuild(userID uint, subscriptionPlan string, req *B
 	build.MaxRetries = maxRetries
 	build.MaxRequests = maxRequests
 	build.MaxTokensPerRequest = maxTokens
+	build.FileBudget = resolveBuildFileBudget(build.SubscriptionPlan, req.MaxFiles)
 
 	am.builds[buildID] = build
 	applog.Operation("build.queue.enqueued", map[string]any{
@@ -949,6 +984,25 @@ func (am *AgentManager) StartBuild(buildID string) error {
 		},
 	})
 
+	if hookErr := am.runBuildHooks(build, buildhooks.StagePreBuild, nil); hookErr != "" {
+		build.mu.Lock()
+		build.Status = BuildFailed
+		build.Error = hookErr
+		build.UpdatedAt = time.Now()
+		build.mu.Unlock()
+		am.persistBuildSnapshot(build, nil)
+		am.broadcast(buildID, &WSMessage{
+			Type:      WSBuildError,
+			BuildID:   buildID,
+			Timestamp: time.Now(),
+			Data: map[string]any{
+				"error":   "Pre-build hook failed",
+				"details": hookErr,
+			},
+		})
+		return errors.New(hookErr)
+	}
+
 	usePlatformKeys := am.buildUsesPlatformKeys(build)
 	if !usePlatformKeys && !am.userHasActiveBYOKKey(build.UserID) {
 		build.mu.Lock()
@@ -2672,6 +2726,7 @@ func (am *AgentManager) SpawnAgentTeam(buildID string) error {
 
 	// Determine provider assignments — respects user overrides if provided
 	providerAssignments := am.assignProvidersToRolesWithOverrides(build, availableProviders, roles, build.RoleAssignments)
+	applyAgentProfileProviders(build, providerAssignments, availableProviders)
 
 	// Broadcast provider availability status
 	providerNames := make([]string, len(availableProviders))
@@ -5035,6 +5090,7 @@ func (am *AgentManager) recordTaskExecutionOutcome(build *Build, agent *Agent, t
 		plTaskDone := pLog(build.ID).TaskDone(task.ID, string(task.Type), agent.ID, string(agent.Role), string(providerUsed))
 		plTaskDone(success, errMsg, fileCount)
 	}
+	recordModelTaskOutcome(build, agent, task, output, providerUsed, success)
 
 	shape := taskExecutionShape(task, agent)
 	if shape == "" {
@@ -5580,7 +5636,7 @@ func (am *AgentManager) executeTask(task *Task) {
 		strategy := string(task.RetryStrategy)
 		switch strategy {
 		case "backoff":
-			delay := time.Duration(task.RetryCount) * 2 * time.Second
+			delay := taskBackoffDelay(task)
 			log.Printf("Backoff strategy: waiting %v before retry (attempt %d)", delay, task.RetryCount)
 			time.Sleep(delay)
 		case "switch_provider":
@@ -6438,6 +6494,16 @@ func (am *AgentManager) processResult(result *TaskResult) {
 		return
 	}
 
+	// Hold results that land while the build is paused; ResumeBuild applies them.
+	if buildErr == nil && !buildInactive {
+		if interaction, parked := parkTaskResultIfPaused(build, result); parked {
+			agent.mu.Unlock()
+			am.persistBuildSnapshot(build, nil)
+			am.broadcastInteractionUpdate(build.ID, interaction)
+			return
+		}
+	}
+
 	// Drop stale in-flight results after build termination to prevent retry storms.
 	if buildInactive {
 		if task != nil && task.Status != TaskCompleted && task.Status != TaskFailed {
@@ -6464,6 +6530,8 @@ func (am *AgentManager) processResult(result *TaskResult) {
 		}
 
 		am.pruneEchoedExistingFiles(build, result.Output)
+		am.enforceBuildFileBudget(build, task, result.Output)
+		am.keepScaffoldBoilerplate(build, task, result.Output)
 
 		var taskVerificationReport *VerificationReport
 		if task != nil {
@@ -6622,6 +6690,7 @@ func (am *AgentManager) processResult(result *TaskResult) {
 			}
 			if buildErr == nil && task != nil {
 				am.recordTaskExecutionOutcome(build, agent, task, result.Output, true, am.isCodeGenerationTask(task.Type), true, "")
+				am.resolveConsensusRounds(build, task.ID, true)
 			}
 
 			agent.Status = StatusCompleted
@@ -6750,6 +6819,10 @@ func (am *AgentManager) processResult(result *TaskResult) {
 			}
 		}
 
+		if !nonRetriable {
+			retryStrategy = am.planRateLimitedRetry(build, agent, task, result.Error, retryStrategy)
+		}
+
 		// Check if we should retry
 		if task.RetryCount < task.MaxRetries && !nonRetriable {
 			// Analyze error and prepare for retry
@@ -6830,6 +6903,9 @@ func (am *AgentManager) processResult(result *TaskResult) {
 			task.Error = agent.Error
 			agent.UpdatedAt = time.Now()
 			agent.mu.Unlock()
+			if buildErr == nil {
+				am.resolveConsensusRounds(build, task.ID, false)
+			}
 
 			// Broadcast final failure
 			am.broadcast(agent.BuildID, &WSMessage{
@@ -6880,7 +6956,7 @@ func (am *AgentManager) handleTaskCompletion(buildID string, task *Task, output
 	case TaskPlan:
 		// Planning completed - parse plan and spawn agents
 		am.handlePlanCompletion(build, output)
-	case TaskGenerateFile, TaskGenerateUI, TaskGenerateAPI, TaskGenerateSchema, TaskArchitecture:
+	case TaskGenerateFile, TaskGenerateUI, TaskGenerateAPI, TaskGenerateSchema, TaskArchitecture, TaskDockerize:
 		// Code/architecture generated - broadcast files and update progress
 		am.handleFileGeneration(build, output)
 	case TaskTest:
@@ -6908,6 +6984,9 @@ func (am *AgentManager) handleTaskCompletion(buildID string, task *Task, output
 // handlePlanCompletion processes the build plan and spawns the agent team
 func (am *AgentManager) handlePlanCompletion(build *Build, output *TaskOutput) {
 	log.Printf("handlePlanCompletion called for build %s", build.ID)
+	if am.holdPlanForApproval(build, output) {
+		return
+	}
 	contractBlocked := false
 	contractBlocker := ""
 	warRoomTelemetry := false
@@ -7355,6 +7434,8 @@ func roleForTaskType(taskType TaskType) AgentRole {
 		return RoleReviewer
 	case TaskFix:
 		return RoleSolver
+	case TaskDockerize:
+		return RoleDevOps
 	default:
 		return RoleSolver
 	}
@@ -7999,6 +8080,7 @@ func (am *AgentManager) handleReviewCompletion(build *Build, sourceTask *Task, o
 	if output == nil {
 		return
 	}
+	am.runCodingStandardsCheck(build)
 
 	// Parse review output for critical issues
 	hasCritical := false
@@ -14680,7 +14762,7 @@ func (am *AgentManager) applyDeterministicExternalImportExportRepair(build *Buil
 		return nil, ""
 	}
 
-	replacementsByFileModule := map[string]map[string]string{}
+	replacementsByFileModule := ai.ModelPreferences{}
 	for _, target := range targets {
 		module := strings.TrimSpace(target.Specifier)
 		if !isExternalGeneratedModuleSpecifier(module) || target.ExportName == "" {
@@ -22529,6 +22611,7 @@ func deterministicRepairScoringBuild(build *Build) *Build {
 		RequirePreviewReady:         build.RequirePreviewReady,
 		Description:                 build.Description,
 		TechStack:                   cloneTechStack(build.TechStack),
+		I18n:                        cloneBuildI18nOptions(build.I18n),
 		Plan:                        cloneBuildPlan(build.Plan),
 		MaxAgents:                   build.MaxAgents,
 		MaxRetries:                  build.MaxRetries,
@@ -23263,6 +23346,18 @@ func (am *AgentManager) runBuildFinalization(build *Build, snapshot buildComplet
 				build.mu.RUnlock()
 				return
 			}
+			if status == BuildCompleted {
+				if hookErr := am.runBuildHooks(build, buildhooks.StagePostBuild, allFiles); hookErr != "" {
+					now = time.Now()
+					build.mu.Lock()
+					build.Status = BuildFailed
+					build.Error = hookErr
+					build.CompletedAt = &now
+					build.UpdatedAt = now
+					build.mu.Unlock()
+					status = BuildFailed
+				}
+			}
 			if status == BuildCompleted {
 				build.mu.Lock()
 				if build.Status != BuildFailed && build.Status != BuildCancelled {
@@ -23322,6 +23417,8 @@ completion_finalize:
 		})
 	}
 
+	costReport := am.finalizeCostOptimizationReport(build, now)
+
 	var approvalInteraction BuildInteractionState
 	approvalPrompted := false
 	if status == BuildCompleted {
@@ -23347,6 +23444,12 @@ completion_finalize:
 			approvalInteraction = copyBuildInteractionStateLocked(build)
 			build.mu.Unlock()
 		}
+		fileConfidence := am.scoreFileConfidence(build, allFiles)
+		// Record where every file came from before the files are persisted
+		// and linked so apex.build.json ships with the build.
+		allFiles = am.attachBuildProvenance(build, allFiles, now)
+		allFiles, environmentDrift := am.attachEnvironmentLock(build, allFiles, now)
+		envVarCheck := am.checkEnvironmentVariables(build, allFiles)
 		am.markBuildTerminalSuccessSnapshot(build, "complete")
 
 		// Persist completion first so a completed_build row exists before auto-linking a project.
@@ -23365,6 +23468,10 @@ completion_finalize:
 				"progress":              progress,
 				"files_count":           len(allFiles),
 				"files":                 allFiles,
+				"file_confidence":       fileConfidence,
+				"cost_optimization":     costReport,
+				"environment_drift":     environmentDrift,
+				"env_var_check":         envVarCheck,
 				"quality_gate_required": true,
 				"quality_gate_passed":   true,
 				"quality_gate_stage":    "complete",
@@ -23422,6 +23529,7 @@ completion_finalize:
 				"progress":              progress,
 				"files_count":           len(allFiles),
 				"files":                 allFiles,
+				"cost_optimization":     costReport,
 				"quality_gate_required": true,
 				"quality_gate_passed":   false,
 				"quality_gate_stage":    "validation",
@@ -23723,6 +23831,7 @@ func (am *AgentManager) persistBuildSnapshotWithRetry(build *Build, files []Gene
 			}},
 		}).Create(snapshot).Error
 		if lastErr == nil {
+			am.reportBuildActivity(snapshot)
 			return nil
 		}
 		if attempt < attempts {
@@ -23801,6 +23910,10 @@ func (am *AgentManager) finalizePhasedPipeline(build *Build) {
 		}
 	}
 
+	if am.holdDeployForApproval(build) {
+		return
+	}
+
 	build.mu.Lock()
 	build.PhasedPipelineComplete = true
 	if build.Status != BuildFailed && build.Status != BuildCancelled && build.Progress < 95 {
@@ -23827,6 +23940,7 @@ func (am *AgentManager) crossAgentFileCoherenceErrors(build *Build) []string {
 // persistCompletedBuild remains as a compatibility alias used by orchestrator paths.
 func (am *AgentManager) persistCompletedBuild(build *Build, files []GeneratedFile) {
 	am.persistBuildSnapshot(build, files)
+	am.recordBuildOutcome(build)
 }
 
 // ensureProjectLinkedForCompletedBuild creates (or reuses) a project for a completed build
@@ -25338,10 +25452,17 @@ func (am *AgentManager) startExecutionPhase(build *Build, description string, ph
 		return nil
 	}
 
-	phaseStatus := phase.status
 	now := time.Now()
 	setBuildPhaseSnapshot(build, phase, now)
+	am.broadcastExecutionPhaseStart(build, phase, phaseIndex, phaseTotal, now)
 
+	taskIDs := am.assignPhaseAgents(build, phase.agents, description)
+	am.persistBuildSnapshot(build, nil)
+	return taskIDs
+}
+
+func (am *AgentManager) broadcastExecutionPhaseStart(build *Build, phase executionPhase, phaseIndex int, phaseTotal int, now time.Time) {
+	phaseStatus := phase.status
 	log.Printf("Build %s: Starting phase — %s (%d agents)", build.ID, phase.name, len(phase.agents))
 
 	am.broadcast(build.ID, &WSMessage{
@@ -25362,10 +25483,6 @@ func (am *AgentManager) startExecutionPhase(build *Build, description string, ph
 			"user_update":           true,
 		},
 	})
-
-	taskIDs := am.assignPhaseAgents(build, phase.agents, description)
-	am.persistBuildSnapshot(build, nil)
-	return taskIDs
 }
 
 func taskMatchesExecutionPhase(task *Task, phase executionPhase) bool {
@@ -25431,9 +25548,10 @@ func (am *AgentManager) recoverStalledPhasedExecution(build *Build) bool {
 	build.mu.RLock()
 	currentPhase := build.SnapshotState.CurrentPhase
 	phasedComplete := build.PhasedPipelineComplete
+	graphRunning := build.TaskGraphRunning
 	blockedByInteraction := buildInteractionBlocksExecution(build)
 	build.mu.RUnlock()
-	if phasedComplete || blockedByInteraction {
+	if phasedComplete || graphRunning || blockedByInteraction {
 		return false
 	}
 
@@ -25531,8 +25649,13 @@ func (am *AgentManager) queuePlanTasks(build *Build) {
 		agentCount += len(phase.agents)
 	}
 
-	// Execute phases in order in a goroutine (non-blocking)
-	go am.executePhasedTasks(build, description, phases)
+	// Execute phases in order in a goroutine (non-blocking). Full builds run
+	// the phases as a task graph so independent work overlaps.
+	if taskGraphExecutionEnabled(build) {
+		go am.executeTaskGraph(build, description, phases)
+	} else {
+		go am.executePhasedTasks(build, description, phases)
+	}
 
 	log.Printf("Started phased task execution for build %s (%d agents)", build.ID, agentCount)
 }
@@ -25548,7 +25671,6 @@ func (am *AgentManager) executePhasedTasks(build *Build, description string, pha
 			continue
 		}
 		phaseIndex++
-		phaseStatus := phase.status
 		taskIDs := am.startExecutionPhase(build, description, phase, phaseIndex, phaseTotal)
 		if !am.waitForPhaseCompletion(build, taskIDs) {
 			log.Printf("Build %s: Phase %s aborted (build cancelled or timed out)", build.ID, phase.name)
@@ -25577,25 +25699,29 @@ func (am *AgentManager) executePhasedTasks(build *Build, description string, pha
 			}
 		}
 
-		log.Printf("Build %s: Phase %s complete", build.ID, phase.name)
-		am.broadcast(build.ID, &WSMessage{
-			Type:      WSBuildProgress,
-			BuildID:   build.ID,
-			Timestamp: time.Now(),
-			Data: map[string]any{
-				"phase":              phase.name,
-				"phase_key":          phase.key,
-				"status":             string(phaseStatus),
-				"quality_gate_stage": phase.qualityStage,
-				"message":            phase.completionMessage,
-				"user_update":        true,
-			},
-		})
+		am.broadcastExecutionPhaseComplete(build, phase)
 	}
 
 	am.finalizePhasedPipeline(build)
 }
 
+func (am *AgentManager) broadcastExecutionPhaseComplete(build *Build, phase executionPhase) {
+	log.Printf("Build %s: Phase %s complete", build.ID, phase.name)
+	am.broadcast(build.ID, &WSMessage{
+		Type:      WSBuildProgress,
+		BuildID:   build.ID,
+		Timestamp: time.Now(),
+		Data: map[string]any{
+			"phase":              phase.name,
+			"phase_key":          phase.key,
+			"status":             string(phase.status),
+			"quality_gate_stage": phase.qualityStage,
+			"message":            phase.completionMessage,
+			"user_update":        true,
+		},
+	})
+}
+
 // waitForFrontendUIApproval blocks executePhasedTasks after the frontend_ui phase
 // completes for paid fullstack builds. It sets WaitingForUser, broadcasts the
 // approval prompt, then polls until the user sends any message (which clears
@@ -25766,6 +25892,12 @@ func (am *AgentManager) failBuildOnPhaseAbort(build *Build, phaseName string, ph
 
 // assignPhaseAgents creates tasks for a group of agents and assigns them.
 func (am *AgentManager) assignPhaseAgents(build *Build, agents []agentPriority, description string) []string {
+	return am.assignPhaseAgentsWithDependencies(build, agents, description, nil)
+}
+
+// assignPhaseAgentsWithDependencies is assignPhaseAgents for tasks that consume
+// the output of the given upstream tasks.
+func (am *AgentManager) assignPhaseAgentsWithDependencies(build *Build, agents []agentPriority, description string, dependencies []string) []string {
 	taskIDs := make([]string, 0, len(agents))
 	for _, ap := range agents {
 		agent := ap.agent
@@ -25824,6 +25956,9 @@ func (am *AgentManager) assignPhaseAgents(build *Build, agents []agentPriority,
 			Input:       taskInput,
 			CreatedAt:   time.Now(),
 		}
+		if len(dependencies) > 0 {
+			task.Dependencies = append([]string(nil), dependencies...)
+		}
 
 		build.mu.Lock()
 		build.Tasks = append(build.Tasks, task)
@@ -26215,7 +26350,7 @@ func normalizeRestoredBuildMode(raw string) BuildMode {
 func normalizeRestoredPowerMode(raw string) PowerMode {
 	mode := PowerMode(strings.TrimSpace(raw))
 	switch mode {
-	case PowerFast, PowerBalanced, PowerMax:
+	case PowerFast, PowerBalanced, PowerMax, PowerBudget:
 		return mode
 	default:
 		return PowerBalanced
@@ -26407,6 +26542,7 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 	providerModelOverrides := map[string]string(nil)
 	phasedPipelineComplete := false
 	diffMode := false
+	fileBudget := 0
 	var techStack *TechStack
 	var plan *BuildPlan
 	var targetPlatform mobile.TargetPlatform
@@ -26415,6 +26551,11 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 	var mobileReleaseLevel mobile.MobileReleaseLevel
 	var mobileCapabilities []mobile.MobileCapability
 	var mobileAppSpec *mobile.MobileAppSpec
+	var i18nOptions *BuildI18nOptions
+	var approvalGates []BuildApprovalGate
+	var agentProfiles []AgentProfile
+	var codingStandards *codestandards.Policy
+	var byokModelPreferences ai.ModelPreferences
 	if restoreContext != nil {
 		if planType := strings.TrimSpace(strings.ToLower(restoreContext.SubscriptionPlan)); planType != "" {
 			subscriptionPlan = planType
@@ -26444,6 +26585,7 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 		providerModelOverrides = normalizeProviderModelOverridesForPowerMode(restoreContext.ProviderModelOverrides, powerMode)
 		phasedPipelineComplete = restoreContext.PhasedPipelineComplete
 		diffMode = restoreContext.DiffMode
+		fileBudget = restoreContext.FileBudget
 		techStack = cloneTechStack(restoreContext.TechStack)
 		plan = cloneBuildPlan(restoreContext.Plan)
 		targetPlatform = restoreContext.TargetPlatform
@@ -26452,6 +26594,11 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 		mobileReleaseLevel = restoreContext.MobileReleaseLevel
 		mobileCapabilities = effectiveMobileCapabilities(restoreContext.MobileCapabilities, nil)
 		mobileAppSpec = restoreContext.MobileAppSpec
+		i18nOptions = normalizeBuildI18nOptions(restoreContext.I18n)
+		approvalGates = normalizeBuildApprovalGates(restoreContext.ApprovalGates)
+		agentProfiles = mergeAgentProfiles(restoreContext.AgentProfiles)
+		codingStandards = restoreContext.CodingStandards
+		byokModelPreferences = cloneBYOKModelPreferences(restoreContext.ModelPreferences)
 	}
 	if techStack == nil && strings.TrimSpace(snapshot.TechStack) != "" {
 		var restoredStack TechStack
@@ -26481,6 +26628,11 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 		MobileReleaseLevel:          mobileReleaseLevel,
 		MobileCapabilities:          mobileCapabilities,
 		MobileAppSpec:               mobileAppSpec,
+		I18n:                        i18nOptions,
+		ApprovalGates:               approvalGates,
+		AgentProfiles:               agentProfiles,
+		CodingStandards:             codingStandards,
+		ModelPreferences:            byokModelPreferences,
 		Agents:                      parseBuildAgents(snapshot.AgentsJSON),
 		Tasks:                       parseBuildTasks(snapshot.TasksJSON),
 		Checkpoints:                 parseBuildCheckpoints(snapshot.CheckpointsJSON),
@@ -26489,6 +26641,7 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 		MaxRetries:                  maxRetries,
 		MaxRequests:                 maxRequests,
 		MaxTokensPerRequest:         maxTokens,
+		FileBudget:                  fileBudget,
 		RequestsUsed:                requestsUsed,
 		ReadinessRecoveryAttempts:   readinessRecoveryAttempts,
 		PreviewVerificationAttempts: previewVerificationAttempts,
@@ -26518,6 +26671,8 @@ func (am *AgentManager) restoreBuildSessionFromSnapshotWithOptions(snapshot *mod
 	if build.Checkpoints == nil {
 		build.Checkpoints = make([]*Checkpoint, 0)
 	}
+	// Parked results live only in memory; their tasks are re-queued on resume.
+	build.Interaction.ParkedResults = 0
 	if build.SnapshotState.PolicyState != nil {
 		build.SubscriptionPlan = strings.TrimSpace(strings.ToLower(build.SnapshotState.PolicyState.PlanType))
 	}
@@ -27399,6 +27554,7 @@ Rules:
 	response, err := am.aiRouter.Generate(ctx, agent.Provider, prompt, GenerateOptions{
 		UserID:          build.UserID,
 		BuildID:         build.ID,
+		AgentID:         agent.ID,
 		MaxTokens:       2000,
 		Temperature:     am.getTemperatureForRole(RoleLead),
 		SystemPrompt:    am.getSystemPrompt(RoleLead, build),
@@ -28626,6 +28782,12 @@ func (am *AgentManager) RollbackToCheckpoint(buildID, checkpointID string) error
 
 // CancelBuild cancels a running build and updates its status
 func (am *AgentManager) CancelBuild(buildID string) error {
+	return am.cancelBuildWithReason(buildID, "cancelled by user")
+}
+
+// cancelBuildWithReason stops a build and records why; reason completes the
+// sentence "Build ..." in the broadcast message.
+func (am *AgentManager) cancelBuildWithReason(buildID string, reason string) error {
 	am.mu.RLock()
 	build, exists := am.builds[buildID]
 	am.mu.RUnlock()
@@ -28643,15 +28805,41 @@ func (am *AgentManager) CancelBuild(buildID string) error {
 	now := time.Now().UTC()
 	build.CompletedAt = &now
 	build.UpdatedAt = now
-	build.Error = "cancelled by user"
+	build.Error = reason
 	build.Interaction.Paused = false
 	build.Interaction.PauseReason = ""
+	build.ParkedTaskResults = nil
+	build.Interaction.ParkedResults = 0
 	refreshInteractionAttentionLocked(build)
+
+	// Stop outstanding work so no agent keeps spending on a cancelled build.
+	cancelledTasks := 0
+	runningTaskIDs := make([]string, 0)
+	for _, task := range build.Tasks {
+		if task == nil {
+			continue
+		}
+		if task.Status == TaskPending || task.Status == TaskInProgress {
+			if task.Status == TaskInProgress {
+				runningTaskIDs = append(runningTaskIDs, task.ID)
+			}
+			task.Status = TaskCancelled
+			cancelledTasks++
+		}
+	}
 	build.mu.Unlock()
 
-	am.persistBuildSnapshot(build, nil)
+	for _, taskID := range runningTaskIDs {
+		am.cancelTaskExecution(taskID)
+	}
 
-	log.Printf("Build %s cancelled by user", buildID)
+	// Keep whatever was generated so far as a restorable checkpoint and in the
+	// persisted snapshot.
+	am.createCheckpoint(build, "Build Cancelled", "Build was cancelled; partial output preserved")
+	files := am.collectGeneratedFiles(build)
+	am.persistCompletedBuild(build, files)
+
+	log.Printf("Build %s %s (%d files preserved, %d tasks cancelled)", buildID, reason, len(files), cancelledTasks)
 
 	// Broadcast cancellation to subscribers
 	go am.broadcast(buildID, &WSMessage{
@@ -28659,8 +28847,10 @@ func (am *AgentManager) CancelBuild(buildID string) error {
 		BuildID:   buildID,
 		Timestamp: now,
 		Data: map[string]interface{}{
-			"message": "Build cancelled by user",
-			"status":  string(BuildCancelled),
+			"message":         "Build " + reason,
+			"status":          string(BuildCancelled),
+			"files_count":     len(files),
+			"cancelled_tasks": cancelledTasks,
 		},
 	})
 
@@ -29068,6 +29258,12 @@ Analyze what went wrong and use a DIFFERENT, CORRECTED approach this time.
 	if build != nil && build.TechStack != nil {
 		techStackContext = buildTechStackDirective(build.TechStack, agent)
 	}
+	if build != nil && build.Plan != nil {
+		if preload := scaffoldPreloadDirective(build.Plan); preload != "" {
+			techStackContext = strings.TrimSpace(techStackContext + "\n" + preload)
+		}
+	}
+	i18nContext := i18nPromptContext(build, agent)
 	workOrderArtifact := taskArtifactWorkOrderFromInput(task)
 	workOrder := taskWorkOrderFromInput(task)
 	buildSpecContext := ""
@@ -29358,12 +29554,14 @@ App being built: %s
 %s
 %s
 %s
+%s
 %s`,
 		task.Type,
 		task.Description,
 		appDescription,
 		assuranceContext,
 		techStackContext,
+		i18nContext,
 		errorContext,
 		restartFailureContext,
 		repairHintsContext,
@@ -29757,6 +29955,11 @@ ABSOLUTE RULES:
 			techHint += fmt.Sprintf(" %s for styling.", ts.Styling)
 		}
 	}
+	if len(build) > 0 {
+		techHint += am.projectInstructionsPromptContext(build[0])
+		techHint += codingStandardsPromptContext(build[0])
+		techHint += agentProfilePromptContext(build[0], role)
+	}
 
 	localStrictStackLock := ""
 	localStrictScopeHint := ""
@@ -35045,7 +35248,7 @@ func (am *AgentManager) runFailureConsensus(
 	task *Task,
 	taskErr error,
 	defaultStrategy string,
-) (consensusDecision, []providerVote) {
+) (consensusDecision, []ConsensusVote) {
 	if build == nil || task == nil || taskErr == nil {
 		return am.strategyToDecision(defaultStrategy), nil
 	}
@@ -35080,7 +35283,8 @@ func (am *AgentManager) runFailureConsensus(
 		)
 	}
 
-	votes := make([]providerVote, 0, len(selected))
+	weighted := am.weighConsensusVoters(build, selected)
+	votes := make([]ConsensusVote, 0, len(selected))
 	for _, provider := range selected {
 		build.mu.Lock()
 		if build.MaxRequests > 0 && build.RequestsUsed >= build.MaxRequests {
@@ -35136,6 +35340,10 @@ func (am *AgentManager) runFailureConsensus(
 						"current_usd": preAuth.CurrentUSD,
 					},
 				})
+				if len(votes) > 0 {
+					_, tally, _ := tallyConsensusVotes(votes)
+					am.recordConsensusRound(build, agent, task, taskErr, defaultStrategy, votes, fallbackDecision, tally, ConsensusReasonBudget)
+				}
 				return fallbackDecision, votes
 			}
 		}
@@ -35160,12 +35368,11 @@ func (am *AgentManager) runFailureConsensus(
 		})
 		cancel()
 
-		vote := providerVote{
-			Provider: provider,
-			Decision: fallbackDecision,
-		}
+		vote := weighted[provider]
+		vote.Decision = fallbackDecision
 		if err != nil {
 			vote.Rationale = fmt.Sprintf("fallback vote due to provider error: %v", err)
+			vote.Fallback = true
 		} else {
 			if am.spendTracker != nil && resp.Usage != nil {
 				projectID := build.ProjectID
@@ -35200,38 +35407,16 @@ func (am *AgentManager) runFailureConsensus(
 		return fallbackDecision, votes
 	}
 
-	counts := map[consensusDecision]int{}
-	for _, vote := range votes {
-		counts[vote.Decision]++
-	}
-
-	winning := fallbackDecision
-	best := 0
-	for decision, count := range counts {
-		if count > best {
-			best = count
-			winning = decision
-		}
-	}
-
-	majorityNeeded := 2
-	if len(votes) == 2 {
-		majorityNeeded = 2
-	}
-	if best < majorityNeeded {
-		winning = fallbackDecision
-	}
-	// Confidence threshold: if fewer than 2 providers actually voted (others errored or abstained),
-	// default to switch_provider as the safest option rather than acting on a minority decision.
-	if best < 2 {
-		log.Printf("Build %s: consensus confidence too low (%d/%d votes for %s); defaulting to switch_provider",
-			build.ID, best, len(votes), winning)
-		winning = decisionSwitchProvider
+	winning, tally, reason := tallyConsensusVotes(votes)
+	if reason == ConsensusReasonNoMajority {
+		log.Printf("Build %s: no weighted majority across %d consensus votes (%v); defaulting to switch_provider",
+			build.ID, len(votes), tally)
 	}
+	am.recordConsensusRound(build, agent, task, taskErr, defaultStrategy, votes, winning, tally, reason)
 
 	summary := make([]string, 0, len(votes))
 	for _, vote := range votes {
-		summary = append(summary, fmt.Sprintf("%s=%s", vote.Provider, vote.Decision))
+		summary = append(summary, fmt.Sprintf("%s=%s (%.2f)", vote.Provider, vote.Decision, vote.Weight))
 	}
 	am.broadcast(build.ID, &WSMessage{
 		Type:      WSBuildProgress,
@@ -35242,6 +35427,8 @@ func (am *AgentManager) runFailureConsensus(
 			"message":            fmt.Sprintf("Provider vote: %s → %s", strings.Join(summary, ", "), winning),
 			"consensus_decision": winning,
 			"consensus_votes":    votes,
+			"consensus_tally":    tally,
+			"consensus_reason":   reason,
 		},
 	})
 	am.broadcast(build.ID, &WSMessage{
@@ -35547,6 +35734,7 @@ func (am *AgentManager) completeTruncatedFiles(
 	output *TaskOutput,
 ) {
 	remainingTruncated := make([]string, 0, len(output.TruncatedFiles))
+	continuations := 0
 
 	for _, path := range output.TruncatedFiles {
 		// Find the truncated file in the output.
@@ -35562,103 +35750,20 @@ func (am *AgentManager) completeTruncatedFiles(
 			continue
 		}
 
-		// Use the last OverlapLines as context so the model picks up exactly
-		// where it stopped without repeating already-written code.
-		lines := strings.Split(target.Content, "\n")
-		ctxStart := len(lines) - OverlapLines
-		if ctxStart < 0 {
-			ctxStart = 0
-		}
-		ctxLines := lines[ctxStart:]
-		ctxText := strings.Join(ctxLines, "\n")
-
-		continuationPrompt := fmt.Sprintf(
-			"You were generating the file `%s` but the response was cut off mid-file.\n\n"+
-				"Here are the last %d lines you wrote:\n\n```\n%s\n```\n\n"+
-				"Continue EXACTLY from where the code was cut off. "+
-				"Output ONLY the continuation — do not repeat the lines shown above. "+
-				"No explanation, no markdown fences.",
-			path, len(ctxLines), ctxText,
-		)
-
-		if am.budgetEnforcer != nil {
-			preAuth, preAuthErr := am.budgetEnforcer.PreAuthorizeForProject(build.UserID, build.ProjectID, build.ID, estimatedRequestCostUSDForBuild(build))
-			if preAuthErr == nil && !preAuth.Allowed {
-				log.Printf("[chunked] continuation blocked by budget cap for %s in task %s", path, task.ID)
-				remainingTruncated = append(remainingTruncated, path)
-				continue
-			}
-		}
-
-		resp, err := am.aiRouter.Generate(ctx, agent.Provider, continuationPrompt, GenerateOptions{
-			UserID:      build.UserID,
-			BuildID:     build.ID,
-			MaxTokens:   8000,
-			Temperature: 0.1,
-			SystemPrompt: "You are completing a source file that was truncated. " +
-				"Output only the remaining code, starting from exactly where the file was cut off.",
-			RoleHint:        string(RoleSolver),
-			PowerMode:       build.PowerMode,
-			UsePlatformKeys: am.buildUsesPlatformKeys(build),
-		})
-		if err != nil {
-			log.Printf("[chunked] continuation failed for %s in task %s: %v", path, task.ID, err)
+		before := len(target.Content)
+		rounds, complete := am.continueTruncatedFile(ctx, task, build, agent, target)
+		continuations += rounds
+		if !complete {
 			remainingTruncated = append(remainingTruncated, path)
 			continue
 		}
-		if resp == nil {
-			log.Printf("[chunked] continuation returned nil response for %s in task %s", path, task.ID)
-			remainingTruncated = append(remainingTruncated, path)
-			continue
-		}
-
-		if am.spendTracker != nil && resp.Usage != nil {
-			projectID := build.ProjectID
-			am.recordBuildSpend(build, agent, spend.RecordSpendInput{
-				UserID:       build.UserID,
-				ProjectID:    projectID,
-				BuildID:      build.ID,
-				AgentID:      string(RoleSolver),
-				AgentRole:    string(RoleSolver),
-				Provider:     string(actualProviderForAIResponse(resp, agent.Provider)),
-				Model:        ai.GetModelUsed(resp, nil),
-				Capability:   "chunked_continuation",
-				IsBYOK:       !am.buildUsesPlatformKeys(build),
-				InputTokens:  resp.Usage.PromptTokens,
-				OutputTokens: resp.Usage.CompletionTokens,
-				PowerMode:    string(build.PowerMode),
-				Status:       "success",
-			}, task.ID, string(task.Type))
-		}
-
-		continuation := stripCodeFences(strings.TrimSpace(resp.Content))
-		if continuation == "" {
-			log.Printf("[chunked] continuation returned empty content for %s in task %s", path, task.ID)
-			remainingTruncated = append(remainingTruncated, path)
-			continue
-		}
-
-		// Trim overlap — if the model repeated any of the context lines, drop them.
-		continuation = trimLeadingOverlap(target.Content, continuation)
-		if strings.TrimSpace(continuation) == "" {
-			log.Printf("[chunked] continuation only repeated overlap for %s in task %s", path, task.ID)
-			remainingTruncated = append(remainingTruncated, path)
-			continue
-		}
-
-		target.Content = target.Content + "\n" + continuation
-		target.Size = int64(len(target.Content))
-		if isLikelyTruncatableJSTSFile(*target) {
-			if truncationErr := detectLikelyTruncatedJSTSFile(target.Path, target.Content); truncationErr != "" {
-				log.Printf("[chunked] continuation for %s still appears truncated: %s", target.Path, truncationErr)
-				remainingTruncated = append(remainingTruncated, path)
-				continue
-			}
-		}
-		log.Printf("[chunked] completed truncated file %s (+%d bytes)", path, len(continuation))
+		log.Printf("[chunked] completed truncated file %s in %d continuation(s) (+%d bytes)", path, rounds, len(target.Content)-before)
 	}
 
 	output.TruncatedFiles = remainingTruncated
+	if output.Metrics != nil {
+		output.Metrics["truncation_continuations"] = continuations
+	}
 	if len(output.TruncatedFiles) == 0 {
 		output.Messages = removeUnterminatedCodeBlockWarnings(output.Messages)
 	}
@@ -35786,6 +35891,7 @@ func (am *AgentManager) executeChunkedFileRepair(
 		resp, err := am.aiRouter.Generate(ctx, agent.Provider, prompt, GenerateOptions{
 			UserID:      build.UserID,
 			BuildID:     build.ID,
+			AgentID:     agent.ID,
 			MaxTokens:   2000,
 			Temperature: 0.1,
 			SystemPrompt: "You are a precise code editor. Apply the given instruction to the " +
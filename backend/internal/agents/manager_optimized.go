@@ -364,6 +364,8 @@ func (am *OptimizedAgentManager) executeTaskCore(ctx context.Context, task *Task
 	response, err := am.aiRouter.Generate(taskCtx, agent.Provider, prompt, GenerateOptions{
 		UserID:          build.UserID,
 		BuildID:         build.ID,
+		AgentID:         agent.ID,
+		TaskID:          task.ID,
 		MaxTokens:       8000,
 		Temperature:     0.7,
 		SystemPrompt:    systemPrompt,
@@ -534,7 +534,23 @@ func (am *AgentManager) generateTaskOutputWithProvider(
 		waterfallStage = decision.Stage
 		waterfallReason = decision.Reason
 	}
+	var budget *budgetRoute
+	if build.PowerMode == PowerBudget {
+		route := am.planBudgetRoute(build, agent.Role, task, provider)
+		budget = &route
+		callPowerMode = route.Tier
+		if route.Model != "" {
+			model = route.Model
+		}
+		waterfallStage = "budget"
+		waterfallReason = route.Reason
+	}
 	managedPlatformOllama := provider == ai.ProviderOllama && am.buildUsesPlatformKeys(build)
+	if preferredModel := byokModelPreferenceForRole(build, provider, agent.Role); preferredModel != "" {
+		model = preferredModel
+		waterfallStage = "byok_preference"
+		waterfallReason = "byok_capability_model"
+	}
 	if explicitModel := providerModelOverrideForBuild(build, provider); explicitModel != "" {
 		model = explicitModel
 		waterfallStage = "manual_override"
@@ -621,6 +637,8 @@ func (am *AgentManager) generateTaskOutputWithProvider(
 	response, err := am.aiRouter.Generate(attemptCtx, provider, prompt, GenerateOptions{
 		UserID:                  build.UserID,
 		BuildID:                 build.ID,
+		AgentID:                 agent.ID,
+		TaskID:                  task.ID,
 		RequestID:               build.RequestID,
 		OperationID:             build.OperationID,
 		MaxTokens:               maxTokens,
@@ -673,6 +691,9 @@ func (am *AgentManager) generateTaskOutputWithProvider(
 		}
 		am.recordBuildSpend(build, agent, si, task.ID, string(task.Type))
 	}
+	if budget != nil {
+		am.recordBudgetAttempt(build, agent, task, *budget, actualProviderUsed, modelUsed, len(systemPrompt)+len(prompt), maxTokens, response.Usage)
+	}
 
 	output := am.parseTaskOutput(task.Type, response.Content)
 	if output.Metrics == nil {
@@ -690,6 +711,7 @@ func (am *AgentManager) generateTaskOutputWithProvider(
 	attachAIResponseMetrics(output, providerUsed, modelUsed, response)
 	am.materializeStructuredPatchOutput(build, task, output)
 	trackLikelyTruncatedSourceFiles(output)
+	trackOutputLimitTruncation(output, response)
 	output.Metrics["truncation_preflight_detected"] = len(output.TruncatedFiles)
 
 	// Build a fresh Agent value rather than copying *agent — Agent embeds a
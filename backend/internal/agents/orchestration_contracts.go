@@ -374,10 +374,12 @@ type VerificationReport struct {
 	TruthTags              []TruthTag         `json:"truth_tags,omitempty"`
 	ConfidenceScore        float64            `json:"confidence_score,omitempty"`
 	// Canary interaction signals (set only on preview_verification phase reports).
-	CanaryClickCount int       `json:"canary_click_count,omitempty"`
-	CanaryErrorCount int       `json:"canary_error_count,omitempty"`
-	VisionReviewed   bool      `json:"vision_reviewed,omitempty"`
-	GeneratedAt      time.Time `json:"generated_at"`
+	CanaryClickCount int  `json:"canary_click_count,omitempty"`
+	CanaryErrorCount int  `json:"canary_error_count,omitempty"`
+	VisionReviewed   bool `json:"vision_reviewed,omitempty"`
+	// Generated E2E smoke suite signals (preview_verification phase only).
+	SmokeStepsRun int       `json:"smoke_steps_run,omitempty"`
+	GeneratedAt   time.Time `json:"generated_at"`
 }
 
 type PromotionDecision struct {
@@ -496,6 +496,24 @@ func deriveBuildApprovals(build *Build, capabilityState *BuildCapabilityState, p
 		approvals = append(approvals, approval)
 	}
 
+	for _, request := range build.Interaction.ApprovalRequests {
+		approvals = append(approvals, BuildApproval{
+			ID:                      "approval_gate_" + request.ID,
+			Kind:                    fmt.Sprintf("%s_approval", request.Checkpoint),
+			Title:                   request.Title,
+			Status:                  approvalStatusFromApprovalRequest(request),
+			Required:                true,
+			Summary:                 firstNonEmptyString(request.ResolutionNote, request.Summary),
+			Reason:                  request.Summary,
+			SourceType:              "approval_gate",
+			SourceID:                request.ID,
+			Actor:                   "user",
+			AcknowledgementRequired: true,
+			RequestedAt:             request.RequestedAt,
+			ResolvedAt:              request.ResolvedAt,
+		})
+	}
+
 	return approvals
 }
 
@@ -128,14 +128,21 @@ func (am *AgentManager) executeStructuredPlanningTask(ctx context.Context, task
 		return nil, fmt.Errorf("planner produced no build plan")
 	}
 	plan = applyBuildAssurancePolicyToPlan(build, plan)
+	messages := []string{summarizeBuildPlan(plan)}
+	if trimmed := fitPlanToFileBudget(plan, build.FileBudget); len(trimmed) > 0 {
+		message := fmt.Sprintf("Plan trimmed to the %d-file budget: deferred %s", plan.FileBudget, strings.Join(trimmed, ", "))
+		messages = append(messages, message)
+		am.broadcastScopeTrimmed(build, "plan", plan.FileBudget, trimmed, task.ID, message)
+	}
 
 	return &TaskOutput{
-		Messages: []string{summarizeBuildPlan(plan)},
+		Messages: messages,
 		Metrics: map[string]any{
 			"spec_hash":         plan.SpecHash,
 			"scaffold_id":       plan.ScaffoldID,
 			"work_orders":       len(plan.WorkOrders),
 			"planned_files":     len(plan.Files),
+			"trimmed_files":     len(plan.TrimmedFiles),
 			"provider":          string(firstNonEmptyProvider(plannerAdapter.lastProvider, provider)),
 			"selected_provider": string(firstNonEmptyProvider(plannerAdapter.lastProvider, provider)),
 			"model":             firstNonEmptyString(plannerAdapter.lastModel, agent.Model),
@@ -219,6 +226,9 @@ func planningDescriptionForBuild(build *Build) string {
 	if description == "" {
 		return ""
 	}
+	if directive := fileBudgetPlanningDirective(build.FileBudget); directive != "" {
+		description += "\n\n" + directive
+	}
 	if !buildRequiresStaticFrontendFallback(build) {
 		return description
 	}
@@ -36,6 +36,7 @@ type PreviewVerificationResult struct {
 	CanaryPostInteractionChecked bool   // true when the canary completed the post-click settle check
 	CanaryPostInteractionHealthy bool   // false when the preview blanked after basic interactions
 	VisionSeverity               string // "critical", "advisory", "clean", or "" when vision skipped
+	SmokeStepsRun                int    // generated E2E smoke steps replayed against the preview
 }
 
 // BuildPreviewVerifier is the interface the agent manager uses for preview verification.
@@ -171,6 +172,11 @@ func (am *AgentManager) runPreviewVerificationGate(
 
 	checksRun := []string{"preview_entrypoint", "preview_content", "preview_structure"}
 	result := am.previewVerifier.VerifyBuildFiles(ctx, vFiles, isFS)
+	smokeStepsRun := 0
+	if result != nil && result.SmokeStepsRun > 0 {
+		smokeStepsRun = result.SmokeStepsRun
+		checksRun = append(checksRun, "e2e_smoke")
+	}
 
 	// Emit gate telemetry for all outcomes.
 	{
@@ -250,6 +256,7 @@ func (am *AgentManager) runPreviewVerificationGate(
 			CanaryClickCount: canaryClicked,
 			CanaryErrorCount: canaryErrors,
 			VisionReviewed:   visionReviewed,
+			SmokeStepsRun:    smokeStepsRun,
 			GeneratedAt:      now.UTC(),
 		})
 
@@ -377,6 +384,7 @@ func (am *AgentManager) runPreviewVerificationGate(
 		ChecksRun:     failureChecks,
 		Errors:        []string{result.Details},
 		Blockers:      []string{fmt.Sprintf("preview_verification_failed:%s", result.FailureKind)},
+		SmokeStepsRun: smokeStepsRun,
 		GeneratedAt:   now.UTC(),
 	})
 
@@ -1166,6 +1174,8 @@ func previewFailureClass(kind string) string {
 		return "infrastructure"
 	case "backend_missing", "backend_no_listen", "backend_no_routes":
 		return "backend_contract"
+	case "smoke_failed":
+		return "e2e_smoke"
 	default:
 		return "unknown"
 	}
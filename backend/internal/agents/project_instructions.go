@@ -0,0 +1,44 @@
+package agents
+
+import (
+	"context"
+	"log"
+)
+
+// ProjectInstructionsSource renders a project's AI instructions (apex.md and
+// organization instructions) for prompts. Implemented by instructions.Service;
+// wired via SetProjectInstructionsSource in main.go.
+type ProjectInstructionsSource interface {
+	PromptContext(ctx context.Context, userID, projectID uint) (string, error)
+}
+
+// SetProjectInstructionsSource wires project instructions into agent prompts.
+func (am *AgentManager) SetProjectInstructionsSource(source ProjectInstructionsSource) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.projectInstructions = source
+}
+
+// projectInstructionsPromptContext returns the instructions block for builds
+// attached to a project. Builds without a project get nothing.
+func (am *AgentManager) projectInstructionsPromptContext(build *Build) string {
+	if build == nil || build.ProjectID == nil || *build.ProjectID == 0 {
+		return ""
+	}
+	// Wired once at startup; read without am.mu because prompts are built
+	// from paths that may already hold it.
+	source := am.projectInstructions
+	if source == nil {
+		return ""
+	}
+	ctx := am.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	block, err := source.PromptContext(ctx, build.UserID, *build.ProjectID)
+	if err != nil {
+		log.Printf("Could not load project instructions for build %s: %v", build.ID, err)
+		return ""
+	}
+	return block
+}
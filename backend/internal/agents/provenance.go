@@ -0,0 +1,307 @@
+package agents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	appmiddleware "apex-build/internal/middleware"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// BuildProvenanceFileName is the provenance manifest written into every
+	// completed build's files.
+	BuildProvenanceFileName = "apex.build.json"
+	// BuildProvenanceSchema versions the manifest layout.
+	BuildProvenanceSchema = "https://apex.build/schemas/build-provenance/v1"
+)
+
+// File origins in the provenance manifest.
+const (
+	ProvenanceOriginAI       = "ai"       // written by an agent task
+	ProvenanceOriginScaffold = "scaffold" // copied from the plan's scaffold
+	ProvenanceOriginPlatform = "platform" // added or repaired deterministically
+)
+
+// BuildProvenance is the machine-readable record of how a build's files
+// were produced, for AI-generated code traceability.
+type BuildProvenance struct {
+	Schema       string                `json:"$schema"`
+	BuildID      string                `json:"build_id"`
+	ProjectID    *uint                 `json:"project_id,omitempty"`
+	Mode         string                `json:"mode"`
+	PowerMode    string                `json:"power_mode"`
+	ProviderMode string                `json:"provider_mode,omitempty"`
+	StartedAt    time.Time             `json:"started_at"`
+	CompletedAt  time.Time             `json:"completed_at"`
+	Origin       BuildProvenanceOrigin `json:"origin"`
+	Models       []ProvenanceModelUse  `json:"models"`
+	Files        []FileProvenance      `json:"files"`
+}
+
+// BuildProvenanceOrigin records the blueprint and preset the build started
+// from.
+type BuildProvenanceOrigin struct {
+	AppType              string     `json:"app_type,omitempty"`
+	TemplateID           string     `json:"template_id,omitempty"`
+	SecondaryTemplateIDs []string   `json:"secondary_template_ids,omitempty"`
+	ScaffoldID           string     `json:"scaffold_id,omitempty"`
+	PlanSource           string     `json:"plan_source,omitempty"`
+	TechStack            *TechStack `json:"tech_stack,omitempty"`
+}
+
+// ProvenanceModelUse counts the files each provider and model wrote.
+type ProvenanceModelUse struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	Files    int    `json:"files"`
+}
+
+// FileProvenance records who produced one file.
+type FileProvenance struct {
+	Path        string     `json:"path"`
+	SHA256      string     `json:"sha256"`
+	Size        int        `json:"size"`
+	Origin      string     `json:"origin"`
+	Provider    string     `json:"provider,omitempty"`
+	Model       string     `json:"model,omitempty"`
+	AgentRole   string     `json:"agent_role,omitempty"`
+	TaskID      string     `json:"task_id,omitempty"`
+	GeneratedAt *time.Time `json:"generated_at,omitempty"`
+}
+
+// buildProvenance attributes each file to the last code-generation task
+// that wrote it, using the provider and model the task reported, or its
+// agent's when it reported none. Files no task wrote keep their entry from
+// the manifest of a restored build when their content is unchanged.
+func (am *AgentManager) buildProvenance(build *Build, files []GeneratedFile, completedAt time.Time) *BuildProvenance {
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+
+	p := &BuildProvenance{
+		Schema:       BuildProvenanceSchema,
+		BuildID:      build.ID,
+		ProjectID:    build.ProjectID,
+		Mode:         string(build.Mode),
+		PowerMode:    string(build.PowerMode),
+		ProviderMode: build.ProviderMode,
+		StartedAt:    build.CreatedAt.UTC(),
+		CompletedAt:  completedAt.UTC(),
+		Models:       []ProvenanceModelUse{},
+		Files:        make([]FileProvenance, 0, len(files)),
+	}
+	p.Origin.TechStack = build.TechStack
+	scaffold := map[string]bool{}
+	if plan := build.Plan; plan != nil {
+		p.Origin.AppType = plan.AppType
+		p.Origin.TemplateID = plan.TemplateID
+		p.Origin.SecondaryTemplateIDs = plan.SecondaryTemplateIDs
+		p.Origin.ScaffoldID = plan.ScaffoldID
+		p.Origin.PlanSource = plan.Source
+		for _, f := range plan.ScaffoldFiles {
+			scaffold[sanitizeFilePath(f.Path)] = true
+		}
+	}
+	prior := map[string]FileProvenance{}
+	for _, f := range build.SnapshotFiles {
+		if sanitizeFilePath(f.Path) != BuildProvenanceFileName {
+			continue
+		}
+		var previous BuildProvenance
+		if json.Unmarshal([]byte(f.Content), &previous) == nil {
+			for _, entry := range previous.Files {
+				prior[entry.Path] = entry
+			}
+		}
+	}
+
+	type writer struct {
+		task     *Task
+		provider string
+		model    string
+		role     string
+	}
+	writers := map[string]writer{}
+	for _, task := range build.Tasks {
+		if task == nil || task.Output == nil || !am.isCodeGenerationTask(task.Type) {
+			continue
+		}
+		w := writer{task: task}
+		if v, ok := task.Output.Metrics["provider"].(string); ok {
+			w.provider = v
+		}
+		if v, ok := task.Output.Metrics["model"].(string); ok {
+			w.model = v
+		}
+		if agent := build.Agents[task.AssignedTo]; agent != nil {
+			w.role = string(agent.Role)
+			if w.provider == "" {
+				w.provider = string(agent.Provider)
+			}
+			if w.model == "" {
+				w.model = agent.Model
+			}
+		}
+		for _, f := range task.Output.Files {
+			if path := sanitizeFilePath(f.Path); path != "" {
+				writers[path] = w
+			}
+		}
+		for _, path := range task.Output.DeletedFiles {
+			delete(writers, sanitizeFilePath(path))
+		}
+	}
+
+	usage := map[[2]string]int{}
+	for _, f := range files {
+		path := sanitizeFilePath(f.Path)
+		if path == "" || path == BuildProvenanceFileName {
+			continue
+		}
+		sum := sha256.Sum256([]byte(f.Content))
+		entry := FileProvenance{Path: path, SHA256: hex.EncodeToString(sum[:]), Size: len(f.Content)}
+		w, written := writers[path]
+		previous, restored := prior[path]
+		switch {
+		case written:
+			entry.Origin = ProvenanceOriginAI
+			entry.Provider = w.provider
+			entry.Model = w.model
+			entry.AgentRole = w.role
+			entry.TaskID = w.task.ID
+			entry.GeneratedAt = w.task.CompletedAt
+			usage[[2]string{w.provider, w.model}]++
+		case scaffold[path]:
+			entry.Origin = ProvenanceOriginScaffold
+		case restored && previous.SHA256 == entry.SHA256:
+			entry = previous
+			if entry.Origin == ProvenanceOriginAI {
+				usage[[2]string{entry.Provider, entry.Model}]++
+			}
+		default:
+			entry.Origin = ProvenanceOriginPlatform
+		}
+		p.Files = append(p.Files, entry)
+	}
+	sort.Slice(p.Files, func(i, j int) bool { return p.Files[i].Path < p.Files[j].Path })
+	for key, count := range usage {
+		p.Models = append(p.Models, ProvenanceModelUse{Provider: key[0], Model: key[1], Files: count})
+	}
+	sort.Slice(p.Models, func(i, j int) bool {
+		if p.Models[i].Files != p.Models[j].Files {
+			return p.Models[i].Files > p.Models[j].Files
+		}
+		return p.Models[i].Provider+p.Models[i].Model < p.Models[j].Provider+p.Models[j].Model
+	})
+	return p
+}
+
+// attachBuildProvenance writes apex.build.json into the build's files and
+// returns the refreshed file list. The manifest is kept with the snapshot
+// files so every later read of the build's output includes it.
+func (am *AgentManager) attachBuildProvenance(build *Build, files []GeneratedFile, completedAt time.Time) []GeneratedFile {
+	provenance := am.buildProvenance(build, files, completedAt)
+	content, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		log.Printf("Build %s: failed to encode provenance: %v", build.ID, err)
+		return files
+	}
+	manifest := GeneratedFile{
+		Path:     BuildProvenanceFileName,
+		Content:  string(content) + "\n",
+		Language: "json",
+		Size:     int64(len(content) + 1),
+		IsNew:    true,
+	}
+
+	build.mu.Lock()
+	kept := build.SnapshotFiles[:0]
+	for _, f := range build.SnapshotFiles {
+		if sanitizeFilePath(f.Path) != BuildProvenanceFileName {
+			kept = append(kept, f)
+		}
+	}
+	build.SnapshotFiles = append(kept, manifest)
+	build.mu.Unlock()
+
+	out := make([]GeneratedFile, 0, len(files)+1)
+	for _, f := range files {
+		if sanitizeFilePath(f.Path) != BuildProvenanceFileName {
+			out = append(out, f)
+		}
+	}
+	return append(out, manifest)
+}
+
+// GetBuildProvenance returns the apex.build.json recorded for a completed
+// build. With ?path= it returns only that file's entry.
+// GET /api/v1/builds/:buildId/provenance
+func (h *BuildHandler) GetBuildProvenance(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "build history not available"})
+		return
+	}
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var build models.CompletedBuild
+	if err := retryBuildHistoryRead("get_build_provenance", func() error {
+		return h.db.Select("build_id", "files_json").
+			Where("build_id = ? AND user_id = ?", c.Param("buildId"), uid).
+			Order("updated_at DESC").
+			Order("id DESC").
+			First(&build).Error
+	}); err != nil {
+		if buildPlatformIssueFromError(err) != nil {
+			c.JSON(http.StatusServiceUnavailable, buildPlatformIssueResponse(err, "build history not available", "Build provenance is temporarily unavailable because the primary database is offline."))
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "build not found"})
+		return
+	}
+	provenance, found := provenanceFromFiles(build.FilesJSON)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no provenance recorded for this build"})
+		return
+	}
+
+	if path := strings.TrimSpace(c.Query("path")); path != "" {
+		path = sanitizeFilePath(path)
+		for _, f := range provenance.Files {
+			if f.Path == path {
+				c.JSON(http.StatusOK, gin.H{"build_id": provenance.BuildID, "file": f})
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not in build provenance"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"provenance": provenance})
+}
+
+func provenanceFromFiles(filesJSON string) (*BuildProvenance, bool) {
+	files, err := parseBuildFiles(filesJSON)
+	if err != nil {
+		return nil, false
+	}
+	for _, f := range files {
+		if sanitizeFilePath(f.Path) != BuildProvenanceFileName {
+			continue
+		}
+		var provenance BuildProvenance
+		if json.Unmarshal([]byte(f.Content), &provenance) != nil {
+			return nil, false
+		}
+		return &provenance, true
+	}
+	return nil, false
+}
@@ -0,0 +1,108 @@
+package agents
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+func TestAttachBuildProvenance(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	generated := started.Add(2 * time.Minute)
+	build := &Build{
+		ID:        "build-1",
+		Mode:      ModeFull,
+		PowerMode: PowerBalanced,
+		CreatedAt: started,
+		Plan: &BuildPlan{
+			AppType:       "web",
+			TemplateID:    "crm",
+			ScaffoldID:    "react-vite",
+			ScaffoldFiles: []GeneratedFile{{Path: "index.html"}},
+		},
+		Agents: map[string]*Agent{
+			"fe": {ID: "fe", Role: RoleFrontend, Provider: ai.ProviderClaude, Model: "claude-default"},
+			"be": {ID: "be", Role: RoleBackend, Provider: ai.ProviderGPT4, Model: "gpt-default"},
+		},
+		Tasks: []*Task{
+			{ID: "t1", Type: TaskGenerateUI, AssignedTo: "fe", CompletedAt: &generated, Output: &TaskOutput{
+				Files:   []GeneratedFile{{Path: "src/App.tsx"}, {Path: "src/old.ts"}},
+				Metrics: map[string]any{"provider": "claude", "model": "claude-reported"},
+			}},
+			{ID: "t2", Type: TaskFix, AssignedTo: "be", Output: &TaskOutput{
+				Files:        []GeneratedFile{{Path: "server.js"}},
+				DeletedFiles: []string{"src/old.ts"},
+			}},
+			{ID: "t3", Type: TaskPlan, AssignedTo: "be", Output: &TaskOutput{Files: []GeneratedFile{{Path: "README.md"}}}},
+		},
+	}
+	files := []GeneratedFile{
+		{Path: "src/App.tsx", Content: "app"},
+		{Path: "server.js", Content: "server"},
+		{Path: "index.html", Content: "<html>"},
+		{Path: "README.md", Content: "readme"},
+		{Path: "src/old.ts", Content: "old"},
+	}
+
+	am := &AgentManager{}
+	out := am.attachBuildProvenance(build, files, generated)
+	if len(out) != len(files)+1 || out[len(out)-1].Path != BuildProvenanceFileName {
+		t.Fatalf("expected manifest appended, got %d files", len(out))
+	}
+	if len(build.SnapshotFiles) != 1 {
+		t.Fatalf("expected manifest kept with snapshot files, got %d", len(build.SnapshotFiles))
+	}
+	encoded, _ := json.Marshal(out)
+	p, ok := provenanceFromFiles(string(encoded))
+	if !ok {
+		t.Fatal("expected provenance to parse back from build files")
+	}
+	if p.BuildID != "build-1" || p.Origin.TemplateID != "crm" || p.Origin.ScaffoldID != "react-vite" || !p.StartedAt.Equal(started) {
+		t.Fatalf("unexpected build fields %+v", p)
+	}
+
+	byPath := map[string]FileProvenance{}
+	for _, f := range p.Files {
+		byPath[f.Path] = f
+	}
+	if len(byPath) != len(files) {
+		t.Fatalf("expected every file except the manifest, got %d", len(byPath))
+	}
+	app := byPath["src/App.tsx"]
+	if app.Origin != ProvenanceOriginAI || app.Provider != "claude" || app.Model != "claude-reported" || app.AgentRole != "frontend" || app.TaskID != "t1" || app.GeneratedAt == nil {
+		t.Fatalf("unexpected task-reported attribution %+v", app)
+	}
+	if server := byPath["server.js"]; server.Provider != string(ai.ProviderGPT4) || server.Model != "gpt-default" {
+		t.Fatalf("expected agent fallback attribution, got %+v", server)
+	}
+	if byPath["index.html"].Origin != ProvenanceOriginScaffold || byPath["README.md"].Origin != ProvenanceOriginPlatform || byPath["src/old.ts"].Origin != ProvenanceOriginPlatform {
+		t.Fatalf("unexpected non-AI origins %+v", byPath)
+	}
+	if len(p.Models) != 2 || p.Models[0].Files != 1 {
+		t.Fatalf("unexpected model summary %+v", p.Models)
+	}
+
+	// A restored build keeps the attribution of unchanged files it did not regenerate
+	build.Tasks = nil
+	files[0].Content = "app v2"
+	out = am.attachBuildProvenance(build, files, generated)
+	encoded, _ = json.Marshal(out)
+	p, _ = provenanceFromFiles(string(encoded))
+	for _, f := range p.Files {
+		switch f.Path {
+		case "server.js":
+			if f.Origin != ProvenanceOriginAI || f.TaskID != "t2" {
+				t.Fatalf("expected carried-over attribution, got %+v", f)
+			}
+		case "src/App.tsx":
+			if f.Origin != ProvenanceOriginPlatform {
+				t.Fatalf("expected changed file to lose attribution, got %+v", f)
+			}
+		}
+	}
+	if len(build.SnapshotFiles) != 1 {
+		t.Fatalf("expected manifest replaced, got %d snapshot files", len(build.SnapshotFiles))
+	}
+}
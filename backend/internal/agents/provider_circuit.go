@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+// OnProviderCircuitChange forwards platform router circuit breaker transitions.
+// Per-user BYOK routers carry no breakers.
+func (a *AIRouterAdapter) OnProviderCircuitChange(listener func(ai.ProviderCircuitEvent)) {
+	if a == nil || a.router == nil {
+		return
+	}
+	a.router.OnCircuitStateChange(listener)
+}
+
+// handleProviderCircuitEvent is the circuit breaker listener. It runs on the
+// request goroutine that tripped the breaker, so notification is handed off.
+func (am *AgentManager) handleProviderCircuitEvent(event ai.ProviderCircuitEvent) {
+	if event.To == ai.CircuitHalfOpen {
+		return
+	}
+	go am.notifyProviderCircuitChange(event)
+}
+
+// notifyProviderCircuitChange tells every active platform-key build that a
+// provider tripped or recovered.
+func (am *AgentManager) notifyProviderCircuitChange(event ai.ProviderCircuitEvent) {
+	if am == nil {
+		return
+	}
+	am.mu.RLock()
+	builds := make([]*Build, 0, len(am.builds))
+	for _, build := range am.builds {
+		if build != nil {
+			builds = append(builds, build)
+		}
+	}
+	am.mu.RUnlock()
+
+	message := fmt.Sprintf("Provider %s recovered and is accepting requests again", event.Provider)
+	if event.To == ai.CircuitOpen {
+		message = fmt.Sprintf("Provider %s is failing (%s); routing around it until it recovers", event.Provider, strings.ReplaceAll(event.Reason, "_", " "))
+	}
+	for _, build := range builds {
+		build.mu.RLock()
+		affected := !isTerminalBuildStatus(build.Status) && strings.ToLower(strings.TrimSpace(build.ProviderMode)) != "byok"
+		buildID := build.ID
+		build.mu.RUnlock()
+		if !affected {
+			continue
+		}
+		am.broadcast(buildID, &WSMessage{
+			Type:      WSBuildProviderCircuit,
+			BuildID:   buildID,
+			Timestamp: time.Now(),
+			Data: map[string]any{
+				"provider":    string(event.Provider),
+				"state":       string(event.To),
+				"previous":    string(event.From),
+				"reason":      event.Reason,
+				"error_class": event.ErrorClass,
+				"retry_at":    event.RetryAt,
+				"message":     message,
+			},
+		})
+	}
+}
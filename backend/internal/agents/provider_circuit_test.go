@@ -0,0 +1,48 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+func TestProviderCircuitChangeNotifiesActivePlatformBuilds(t *testing.T) {
+	am := &AgentManager{
+		builds:      map[string]*Build{},
+		subscribers: map[string][]chan *WSMessage{},
+	}
+	channels := map[string]chan *WSMessage{}
+	for _, build := range []*Build{
+		{ID: "platform-active", Status: BuildInProgress, ProviderMode: "platform"},
+		{ID: "byok-active", Status: BuildInProgress, ProviderMode: "byok"},
+		{ID: "platform-done", Status: BuildCompleted, ProviderMode: "platform"},
+	} {
+		am.builds[build.ID] = build
+		channels[build.ID] = make(chan *WSMessage, 2)
+		am.Subscribe(build.ID, channels[build.ID])
+	}
+
+	retryAt := time.Now().Add(30 * time.Second)
+	am.notifyProviderCircuitChange(ai.ProviderCircuitEvent{
+		Provider: ai.ProviderClaude, From: ai.CircuitClosed, To: ai.CircuitOpen,
+		Reason: "error_rate", ErrorClass: "timeout", RetryAt: &retryAt,
+	})
+
+	select {
+	case msg := <-channels["platform-active"]:
+		data, _ := msg.Data.(map[string]any)
+		if msg.Type != WSBuildProviderCircuit || data["provider"] != "claude" || data["state"] != "open" || data["error_class"] != "timeout" {
+			t.Fatalf("unexpected circuit message %+v", msg)
+		}
+	default:
+		t.Fatal("active platform build should be notified")
+	}
+	for _, id := range []string{"byok-active", "platform-done"} {
+		select {
+		case msg := <-channels[id]:
+			t.Fatalf("build %s should not be notified, got %+v", id, msg)
+		default:
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package agents
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// RestorePointSnapshotter takes a project-level restore point inside the
+// caller's transaction. Implemented by *restorepoints.Service; wired via
+// SetRestorePoints.
+type RestorePointSnapshotter interface {
+	SnapshotProject(tx *gorm.DB, projectID, userID uint, reason, label string) error
+}
+
+// SetRestorePoints makes applying a build's artifacts to an existing project
+// take a restore point of the files it is about to overwrite.
+func (am *AgentManager) SetRestorePoints(points RestorePointSnapshotter) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.restorePoints = points
+}
+
+// snapshotBeforeApply takes the restore point in the apply transaction, so a
+// failed snapshot rolls the apply back rather than leaving it unprotected.
+func (am *AgentManager) snapshotBeforeApply(tx *gorm.DB, projectID, userID uint, buildID string) error {
+	if am == nil {
+		return nil
+	}
+	am.mu.RLock()
+	points := am.restorePoints
+	am.mu.RUnlock()
+	if points == nil {
+		return nil
+	}
+	return points.SnapshotProject(tx, projectID, userID, "build", fmt.Sprintf("Before applying build %s", buildID))
+}
@@ -0,0 +1,91 @@
+package agents
+
+import (
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+// buildRetryWaitBudget caps how long one build may spend waiting on provider
+// rate limits, across the router's in-call retries and task-level backoff.
+const buildRetryWaitBudget = 5 * time.Minute
+
+// SetRetryBudgetSource lets the adapter charge router retry waits to the
+// build a call belongs to.
+func (a *AIRouterAdapter) SetRetryBudgetSource(source func(buildID string) *ai.RetryBudget) {
+	a.retryBudgets = source
+}
+
+func (a *AIRouterAdapter) retryBudgetFor(buildID string) *ai.RetryBudget {
+	if a == nil || a.retryBudgets == nil || strings.TrimSpace(buildID) == "" {
+		return nil
+	}
+	return a.retryBudgets(buildID)
+}
+
+// BuildRetryBudget returns the build's retry wait budget, creating it on first
+// use. The budget is in memory only and starts fresh after a restart.
+func (am *AgentManager) BuildRetryBudget(buildID string) *ai.RetryBudget {
+	if am == nil {
+		return nil
+	}
+	am.mu.RLock()
+	build := am.builds[buildID]
+	am.mu.RUnlock()
+	if build == nil {
+		return nil
+	}
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	if build.RetryBudget == nil {
+		build.RetryBudget = ai.NewRetryBudget(buildRetryWaitBudget)
+	}
+	return build.RetryBudget
+}
+
+// planRateLimitedRetry tunes a backoff retry to the wait the rate-limited
+// provider asked for. A wait that fits the build's retry budget is scheduled
+// as the task's backoff; otherwise the task switches provider when another is
+// available. With no alternative the task still waits what the provider asked.
+// Callers hold agent.mu.
+func (am *AgentManager) planRateLimitedRetry(build *Build, agent *Agent, task *Task, err error, strategy string) string {
+	if build == nil || agent == nil || task == nil {
+		return strategy
+	}
+	clearTaskRetryAfter(task)
+	if strategy != "backoff" {
+		return strategy
+	}
+	wait, ok := ai.RetryAfterHint(err)
+	if !ok {
+		return strategy
+	}
+	if !am.BuildRetryBudget(build.ID).Reserve(wait) {
+		for _, provider := range am.getCurrentlyAvailableProvidersForBuild(build) {
+			if provider != agent.Provider {
+				return "switch_provider"
+			}
+		}
+	}
+	setTaskInputValues(task, map[string]any{"retry_after_ms": wait.Milliseconds()})
+	return strategy
+}
+
+func clearTaskRetryAfter(task *Task) {
+	task.mu.Lock()
+	delete(task.Input, "retry_after_ms")
+	task.mu.Unlock()
+}
+
+// taskBackoffDelay is the wait before a backoff retry: the provider's
+// advertised wait when known, else a linear backoff.
+func taskBackoffDelay(task *Task) time.Duration {
+	task.mu.RLock()
+	retryAfterMs := taskInputInt(task.Input, "retry_after_ms")
+	task.mu.RUnlock()
+	if retryAfterMs > 0 {
+		return time.Duration(retryAfterMs) * time.Millisecond
+	}
+	return time.Duration(task.RetryCount) * 2 * time.Second
+}
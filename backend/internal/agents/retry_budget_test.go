@@ -0,0 +1,45 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+func TestPlanRateLimitedRetryHonorsHintThenSwitchesWhenBudgetSpent(t *testing.T) {
+	am := &AgentManager{
+		builds:   map[string]*Build{},
+		aiRouter: &stubAIRouter{providers: []ai.AIProvider{ai.ProviderClaude, ai.ProviderGPT4}},
+	}
+	build := &Build{ID: "rate-limited", ProviderMode: "platform"}
+	am.builds[build.ID] = build
+	agent := &Agent{ID: "a1", Provider: ai.ProviderClaude}
+	task := &Task{ID: "t1", RetryCount: 1}
+
+	// The hint survives string-only wrapping on the way up from the router.
+	rateLimited := fmt.Errorf("generation failed: %v", &ai.RateLimitError{Err: errors.New("RATE_LIMIT: slow down"), RetryAfter: 4 * time.Minute})
+	if got := am.planRateLimitedRetry(build, agent, task, rateLimited, "backoff"); got != "backoff" {
+		t.Fatalf("strategy = %q, want backoff while the wait fits the budget", got)
+	}
+	if delay := taskBackoffDelay(task); delay != 4*time.Minute {
+		t.Fatalf("backoff delay = %v, want the provider's 4m", delay)
+	}
+	if remaining := am.BuildRetryBudget(build.ID).Remaining(); remaining != buildRetryWaitBudget-4*time.Minute {
+		t.Fatalf("budget remaining = %v", remaining)
+	}
+
+	if got := am.planRateLimitedRetry(build, agent, task, rateLimited, "backoff"); got != "switch_provider" {
+		t.Fatalf("strategy = %q, want switch_provider once the wait exceeds the budget", got)
+	}
+	if delay := taskBackoffDelay(task); delay != 2*time.Second {
+		t.Fatalf("stale retry-after should be cleared, got %v", delay)
+	}
+
+	am.aiRouter = &stubAIRouter{providers: []ai.AIProvider{ai.ProviderClaude}}
+	if got := am.planRateLimitedRetry(build, agent, task, rateLimited, "backoff"); got != "backoff" || taskBackoffDelay(task) != 4*time.Minute {
+		t.Fatalf("without an alternative provider the task should wait the hint, got %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package agents
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// scaffoldPreloadDirective tells agents which deterministic scaffold files are
+// already in the repo so they spend output on domain code instead of
+// regenerating boilerplate such as package.json or vite.config.ts.
+func scaffoldPreloadDirective(plan *BuildPlan) string {
+	if plan == nil || len(plan.ScaffoldFiles) == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(plan.ScaffoldFiles))
+	for _, file := range plan.ScaffoldFiles {
+		if p := sanitizeFilePath(file.Path); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return ""
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("PRELOADED SCAFFOLD (%s) — these boilerplate files already exist in the repo:\n", plan.ScaffoldID))
+	for _, p := range paths {
+		b.WriteString("- " + p + "\n")
+	}
+	b.WriteString("- Do NOT regenerate or re-emit these files. Build the domain-specific screens, routes, models and logic on top of them.\n")
+	b.WriteString("- Return a scaffold file only when your feature needs a change to it (a new dependency, route registration, entry wiring or starter UI replacement), and then return the complete updated file.\n")
+	b.WriteString("- Never delete scaffold build config (package.json, tsconfig.json, vite/next/tailwind/postcss config, index.html, entry points).")
+	return b.String()
+}
+
+// scaffoldReplacementKey identifies a scaffold file independent of its
+// extension, so vite.config.ts and vite.config.js share a key.
+func scaffoldReplacementKey(p string) string {
+	dir, base := path.Split(p)
+	if idx := strings.Index(base, "."); idx > 0 {
+		base = base[:idx]
+	}
+	return dir + base
+}
+
+// keepScaffoldBoilerplate drops deletions of deterministic scaffold files
+// unless the same output supplies a replacement with the same name and a
+// different extension. A missing vite.config or entry point breaks the
+// preview far more often than a stale one. Docs are not protected. It
+// returns the deletions it refused.
+func (am *AgentManager) keepScaffoldBoilerplate(build *Build, task *Task, output *TaskOutput) []string {
+	if build == nil || output == nil || len(output.DeletedFiles) == 0 {
+		return nil
+	}
+	build.mu.RLock()
+	protected := make(map[string]bool)
+	if build.Plan != nil {
+		for _, file := range build.Plan.ScaffoldFiles {
+			p := sanitizeFilePath(file.Path)
+			if p != "" && !strings.HasSuffix(strings.ToLower(p), ".md") {
+				protected[p] = true
+			}
+		}
+	}
+	build.mu.RUnlock()
+	if len(protected) == 0 {
+		return nil
+	}
+
+	replacements := make(map[string]bool, len(output.Files))
+	for _, file := range output.Files {
+		if p := sanitizeFilePath(file.Path); p != "" && strings.TrimSpace(file.Content) != "" {
+			replacements[scaffoldReplacementKey(p)] = true
+		}
+	}
+
+	var refused []string
+	kept := output.DeletedFiles[:0]
+	for _, deleted := range output.DeletedFiles {
+		p := sanitizeFilePath(deleted)
+		if protected[p] && !replacements[scaffoldReplacementKey(p)] {
+			refused = append(refused, p)
+			continue
+		}
+		kept = append(kept, deleted)
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+	output.DeletedFiles = kept
+	output.Messages = append(output.Messages, fmt.Sprintf("Kept scaffold files the task tried to delete without a replacement: %s", strings.Join(refused, ", ")))
+	if output.Metrics == nil {
+		output.Metrics = map[string]any{}
+	}
+	output.Metrics["deleted_file_count"] = len(output.DeletedFiles)
+
+	taskID := ""
+	if task != nil {
+		taskID = task.ID
+	}
+	log.Printf("Build %s: task %s tried to delete scaffold files %v; keeping them", build.ID, taskID, refused)
+	return refused
+}
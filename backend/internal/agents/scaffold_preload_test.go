@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScaffoldBootstrapFilesCoverEveryRequiredFile(t *testing.T) {
+	tests := []struct {
+		appType string
+		stack   TechStack
+		want    string
+	}{
+		{"web", TechStack{Frontend: "React"}, "frontend/react-vite-spa"},
+		{"api", TechStack{Backend: "Express"}, "api/express-typescript"},
+		{"web", TechStack{Frontend: "Next.js"}, "frontend/nextjs-app"},
+		{"fullstack", TechStack{Frontend: "Next.js", Backend: "Node"}, "fullstack/nextjs-api"},
+		{"api", TechStack{Backend: "FastAPI"}, "api/python-fastapi"},
+		{"fullstack", TechStack{Frontend: "React", Backend: "Express"}, "fullstack/react-vite-express-ts"},
+	}
+	for _, tt := range tests {
+		scaffold := selectBuildScaffold(tt.appType, tt.stack)
+		if scaffold.ID != tt.want {
+			t.Fatalf("selectBuildScaffold(%s, %+v) = %s, want %s", tt.appType, tt.stack, scaffold.ID, tt.want)
+		}
+		generated := make(map[string]bool)
+		for _, file := range scaffoldBootstrapFiles(scaffold, "Team retro board", tt.stack) {
+			generated[file.Path] = strings.TrimSpace(file.Content) != ""
+		}
+		for _, required := range scaffold.Required {
+			if !generated[required.Path] {
+				t.Errorf("%s: required scaffold file %s is not generated", scaffold.ID, required.Path)
+			}
+		}
+	}
+}
+
+func TestScaffoldPreloadDirectiveListsPreloadedFiles(t *testing.T) {
+	if scaffoldPreloadDirective(&BuildPlan{}) != "" {
+		t.Fatal("plans without scaffold files need no directive")
+	}
+	directive := scaffoldPreloadDirective(&BuildPlan{
+		ScaffoldID:    "frontend/react-vite-spa",
+		ScaffoldFiles: []GeneratedFile{{Path: "vite.config.ts"}, {Path: "index.html"}},
+	})
+	for _, want := range []string{"frontend/react-vite-spa", "- index.html\n- vite.config.ts", "Do NOT regenerate"} {
+		if !strings.Contains(directive, want) {
+			t.Fatalf("directive missing %q:\n%s", want, directive)
+		}
+	}
+}
+
+func TestKeepScaffoldBoilerplateRefusesUnreplacedDeletes(t *testing.T) {
+	am := &AgentManager{}
+	build := &Build{ID: "scaffold", Plan: &BuildPlan{ScaffoldFiles: []GeneratedFile{
+		{Path: "vite.config.ts"}, {Path: "index.html"}, {Path: "tailwind.config.js"}, {Path: "README.md"},
+	}}}
+	output := &TaskOutput{
+		Files:        []GeneratedFile{{Path: "tailwind.config.ts", Content: "export default {}"}},
+		DeletedFiles: []string{"vite.config.ts", "tailwind.config.js", "README.md", "src/old.tsx"},
+	}
+
+	refused := am.keepScaffoldBoilerplate(build, &Task{ID: "t"}, output)
+	if want := []string{"vite.config.ts"}; !reflect.DeepEqual(refused, want) {
+		t.Fatalf("refused = %v, want %v", refused, want)
+	}
+	if want := []string{"tailwind.config.js", "README.md", "src/old.tsx"}; !reflect.DeepEqual(output.DeletedFiles, want) {
+		t.Fatalf("deleted = %v, want %v", output.DeletedFiles, want)
+	}
+}
@@ -0,0 +1,142 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"apex-build/internal/buildfailures"
+	"apex-build/internal/supportbundles"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// supportBundleProviderEvents are activity events that describe a provider
+// failing or being replaced, in addition to error entries.
+var supportBundleProviderEvents = map[string]bool{
+	"agent:generation_failed": true,
+	"agent:provider_switched": true,
+	"agent:retrying":          true,
+	string(WSAgentError):      true,
+}
+
+// SupportBundleReport assembles a finished build's diagnostics from its
+// persisted snapshot: the user-visible activity timeline, provider failures,
+// validation state and the build's environment. The supportbundles service
+// redacts it before archiving.
+func (am *AgentManager) SupportBundleReport(ctx context.Context, userID uint, buildID string) (*supportbundles.Report, error) {
+	if am == nil || am.db == nil {
+		return nil, supportbundles.ErrNotFound
+	}
+	var snapshot models.CompletedBuild
+	err := am.db.WithContext(ctx).Where("build_id = ? AND user_id = ?", buildID, userID).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, supportbundles.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch BuildStatus(snapshot.Status) {
+	case BuildCompleted, BuildFailed, BuildCancelled:
+	default:
+		return nil, supportbundles.ErrNotEligible
+	}
+
+	state := parseBuildSnapshotState(snapshot.StateJSON)
+	signals := buildfailures.Signals{Status: snapshot.Status, Error: strings.TrimSpace(snapshot.Error)}
+	if ft := state.FailureTaxonomy; ft != nil {
+		signals.FailureCategory = string(ft.CurrentCategory)
+		if signals.FailureCategory == "" {
+			signals.FailureCategory = string(ft.LastCategory)
+		}
+		signals.FailureClass = strings.TrimSpace(ft.CurrentClass)
+		if signals.FailureClass == "" {
+			signals.FailureClass = strings.TrimSpace(ft.LastClass)
+		}
+	}
+	readinessClass := ""
+	if snapshot.Status == string(BuildFailed) {
+		signals.ErrorClass = normalizeFailureClass(signals.Error)
+		readinessClass = readinessErrorClassFromBuildError(signals.Error)
+		signals.ReadinessClass = readinessClass
+	}
+	cause, detail := buildfailures.Classify(signals)
+
+	report := &supportbundles.Report{
+		Summary: supportbundles.Summary{
+			Kind:        supportbundles.KindBuild,
+			TargetID:    snapshot.BuildID,
+			UserID:      snapshot.UserID,
+			ProjectID:   snapshot.ProjectID,
+			ProjectName: snapshot.ProjectName,
+			Status:      snapshot.Status,
+			Error:       signals.Error,
+			RootCause:   cause,
+			CauseDetail: detail,
+			StartedAt:   snapshot.CreatedAt,
+			FinishedAt:  snapshot.CompletedAt,
+			DurationMs:  snapshot.DurationMs,
+		},
+		Timeline:       []supportbundles.TimelineEntry{},
+		ProviderErrors: []supportbundles.TimelineEntry{},
+	}
+
+	for _, activity := range parseBuildActivityTimeline(snapshot.ActivityJSON) {
+		if activity.IsInternal {
+			continue
+		}
+		entry := supportbundles.TimelineEntry{
+			Timestamp: activity.Timestamp,
+			Source:    activity.AgentRole,
+			Provider:  activity.Provider,
+			Model:     activity.Model,
+			Level:     activity.Type,
+			Event:     activity.EventType,
+			Message:   activity.Content,
+		}
+		report.Timeline = append(report.Timeline, entry)
+		if activity.Type == "error" || supportBundleProviderEvents[activity.EventType] {
+			report.ProviderErrors = append(report.ProviderErrors, entry)
+		}
+	}
+
+	validation := map[string]any{
+		"current_phase":       state.CurrentPhase,
+		"quality_gate_status": state.QualityGateStatus,
+		"quality_gate_stage":  state.QualityGateStage,
+		"readiness_class":     readinessClass,
+		"failure_taxonomy":    state.FailureTaxonomy,
+		"blockers":            state.Blockers,
+	}
+	if state.QualityGateRequired != nil {
+		validation["quality_gate_required"] = *state.QualityGateRequired
+	}
+	environment := map[string]any{
+		"mode":                snapshot.Mode,
+		"power_mode":          snapshot.PowerMode,
+		"target_platform":     snapshot.TargetPlatform,
+		"files_count":         snapshot.FilesCount,
+		"available_providers": state.AvailableProviders,
+	}
+	if rc := state.RestoreContext; rc != nil {
+		validation["compile_validation_passed"] = rc.CompileValidationPassed
+		validation["compile_validation_attempts"] = rc.CompileValidationAttempts
+		validation["compile_validation_repairs"] = rc.CompileValidationRepairs
+		validation["readiness_recovery_attempts"] = rc.ReadinessRecoveryAttempts
+		validation["preview_verification_attempts"] = rc.PreviewVerificationAttempts
+		environment["provider_mode"] = rc.ProviderMode
+		environment["subscription_plan"] = rc.SubscriptionPlan
+		environment["requests_used"] = rc.RequestsUsed
+	}
+	if strings.TrimSpace(snapshot.TechStack) != "" {
+		var techStack any
+		if json.Unmarshal([]byte(snapshot.TechStack), &techStack) == nil {
+			environment["tech_stack"] = techStack
+		}
+	}
+	report.Validation = validation
+	report.Environment = environment
+	return report, nil
+}
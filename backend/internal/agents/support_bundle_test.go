@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"apex-build/internal/buildfailures"
+	"apex-build/internal/supportbundles"
+	"apex-build/pkg/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSupportBundleReportFromSnapshot(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CompletedBuild{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	now := time.Now().UTC()
+	activity, _ := json.Marshal([]BuildActivityEntry{
+		{AgentRole: "planner", Type: "thinking", IsInternal: true, Content: "hidden reasoning", Timestamp: now},
+		{AgentRole: "frontend", Provider: "claude", Type: "action", EventType: "agent:provider_switched", Content: "Switched to gpt", Timestamp: now},
+		{AgentRole: "frontend", Provider: "gpt", Type: "error", EventType: "agent:generation_failed", Content: "429 rate limit", Timestamp: now},
+	})
+	state, _ := json.Marshal(BuildSnapshotState{
+		QualityGateStatus: "failed",
+		RestoreContext:    &BuildRestoreContext{CompileValidationAttempts: 2, ProviderMode: "platform"},
+	})
+	rows := []models.CompletedBuild{
+		{BuildID: "failed-build", UserID: 1, Status: string(BuildFailed), Mode: "full", Error: "openai: 429 rate limit exceeded",
+			ActivityJSON: string(activity), StateJSON: string(state), CompletedAt: &now},
+		{BuildID: "running-build", UserID: 1, Status: string(BuildInProgress)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	am := &AgentManager{db: db}
+	ctx := context.Background()
+
+	report, err := am.SupportBundleReport(ctx, 1, "failed-build")
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if report.Summary.RootCause != buildfailures.CauseProviderError || report.Summary.CauseDetail != "rate_limited" {
+		t.Fatalf("unexpected cause %q/%q", report.Summary.RootCause, report.Summary.CauseDetail)
+	}
+	if len(report.Timeline) != 2 || len(report.ProviderErrors) != 2 {
+		t.Fatalf("expected internal entries dropped and both provider events kept, got %d/%d", len(report.Timeline), len(report.ProviderErrors))
+	}
+	validation := report.Validation.(map[string]any)
+	if validation["quality_gate_status"] != "failed" || validation["compile_validation_attempts"] != 2 {
+		t.Fatalf("unexpected validation %+v", validation)
+	}
+	if report.Environment["provider_mode"] != "platform" {
+		t.Fatalf("unexpected environment %+v", report.Environment)
+	}
+
+	if _, err := am.SupportBundleReport(ctx, 2, "failed-build"); !errors.Is(err, supportbundles.ErrNotFound) {
+		t.Fatalf("expected not found for another user, got %v", err)
+	}
+	if _, err := am.SupportBundleReport(ctx, 1, "running-build"); !errors.Is(err, supportbundles.ErrNotEligible) {
+		t.Fatalf("expected running builds to be ineligible, got %v", err)
+	}
+}
@@ -42,7 +42,7 @@ func (am *AgentManager) runSurfaceDeterministicChecks(build *Build, task *Task,
 	case TaskShapeFrontendPatch:
 		return runFrontendDeterministicChecks(mergedFiles)
 	case TaskShapeBackendPatch, TaskShapeSchema, TaskShapeIntegration:
-		if task.Type == TaskDeploy {
+		if task.Type == TaskDeploy || task.Type == TaskDockerize {
 			return runConfigManifestSanityChecks(candidate.Output)
 		}
 		return runBackendDeterministicChecks(mergedFiles)
@@ -0,0 +1,293 @@
+package agents
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// executionPhaseDependencies lists, for each phase, the phases whose output
+// its tasks consume. Under the task graph a task starts as soon as these are
+// complete instead of waiting for every earlier phase.
+var executionPhaseDependencies = map[string][]string{
+	"architecture":     nil,
+	"frontend_ui":      {"architecture"},
+	"data_foundation":  {"architecture"},
+	"backend_services": {"architecture", "data_foundation"},
+	"parallel_core":    {"architecture"},
+	"integration":      {"frontend_ui", "data_foundation", "backend_services", "parallel_core"},
+	"review":           {"architecture", "frontend_ui", "data_foundation", "backend_services", "parallel_core", "integration"},
+}
+
+// taskGraphExecutionEnabled reports whether a build's phases run as a task
+// graph. Full builds do unless APEX_TASK_GRAPH=false. The frontend approval
+// gate needs the strict phase order, so it keeps builds on the phased loop.
+func taskGraphExecutionEnabled(build *Build) bool {
+	if build == nil || !envBool("APEX_TASK_GRAPH", true) || os.Getenv("APEX_FRONTEND_APPROVAL_GATE") == "true" {
+		return false
+	}
+	build.mu.RLock()
+	defer build.mu.RUnlock()
+	return build.Mode == ModeFull
+}
+
+// taskGraphConcurrency bounds in-flight graph tasks by the build's agent
+// budget, excluding the lead. Zero means unbounded.
+func taskGraphConcurrency(maxAgents int) int {
+	if maxAgents <= 0 {
+		return 0
+	}
+	if maxAgents-1 < 1 {
+		return 1
+	}
+	return maxAgents - 1
+}
+
+// resolveTaskGraphDependencies maps each phase with agents to the phases with
+// agents it waits on. Dependencies on empty phases resolve to their own
+// dependencies, so skipping the database phase leaves backend waiting on
+// architecture. Phases missing from this pipeline are ignored.
+func resolveTaskGraphDependencies(phases []executionPhase) map[string][]string {
+	present := make(map[string]bool, len(phases))
+	active := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		present[phase.key] = true
+		if len(phase.agents) > 0 {
+			active[phase.key] = true
+		}
+	}
+
+	var resolve func(key string, seen map[string]bool) []string
+	resolve = func(key string, seen map[string]bool) []string {
+		var out []string
+		for _, dep := range executionPhaseDependencies[key] {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			if !present[dep] {
+				continue
+			}
+			if active[dep] {
+				out = append(out, dep)
+				continue
+			}
+			out = append(out, resolve(dep, seen)...)
+		}
+		return out
+	}
+
+	resolved := make(map[string][]string, len(active))
+	for _, phase := range phases {
+		if active[phase.key] {
+			resolved[phase.key] = resolve(phase.key, map[string]bool{})
+		}
+	}
+	return resolved
+}
+
+// taskGraphHooks connect runTaskGraph to the build. start creates and queues
+// one agent's task and returns its task IDs; wait blocks until they settle.
+// abort runs once for the first task that fails. phaseDone runs when every
+// task in a phase has completed; returning false stops the graph.
+type taskGraphHooks struct {
+	start     func(phase executionPhase, ap agentPriority, dependencies []string) []string
+	wait      func(phase executionPhase, taskIDs []string) bool
+	abort     func(phase executionPhase, taskIDs []string)
+	phaseDone func(phase executionPhase) bool
+}
+
+type taskGraphPhaseState struct {
+	mu        sync.Mutex
+	remaining int
+	failed    bool
+	taskIDs   []string
+	ok        bool
+	done      chan struct{}
+}
+
+// runTaskGraph runs one task per phase agent, starting each once the phases
+// it depends on are done, with at most limit tasks in flight (0 = no limit).
+// It reports whether every phase completed.
+func runTaskGraph(phases []executionPhase, limit int, hooks taskGraphHooks) bool {
+	dependencies := resolveTaskGraphDependencies(phases)
+	states := make(map[string]*taskGraphPhaseState, len(dependencies))
+	nodes := 0
+	for _, phase := range phases {
+		if len(phase.agents) == 0 {
+			continue
+		}
+		states[phase.key] = &taskGraphPhaseState{remaining: len(phase.agents), done: make(chan struct{})}
+		nodes += len(phase.agents)
+	}
+	if nodes == 0 {
+		return true
+	}
+	if limit <= 0 || limit > nodes {
+		limit = nodes
+	}
+
+	slots := make(chan struct{}, limit)
+	aborted := make(chan struct{})
+	var abortOnce sync.Once
+	stop := func(onFirst func()) {
+		abortOnce.Do(func() {
+			if onFirst != nil {
+				onFirst()
+			}
+			close(aborted)
+		})
+	}
+
+	runNode := func(phase executionPhase, ap agentPriority) bool {
+		var upstream []string
+		for _, dep := range dependencies[phase.key] {
+			select {
+			case <-states[dep].done:
+			case <-aborted:
+				return false
+			}
+			if !states[dep].ok {
+				return false
+			}
+			upstream = append(upstream, states[dep].taskIDs...)
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-aborted:
+			return false
+		}
+		defer func() { <-slots }()
+
+		taskIDs := hooks.start(phase, ap, upstream)
+		state := states[phase.key]
+		state.mu.Lock()
+		state.taskIDs = append(state.taskIDs, taskIDs...)
+		state.mu.Unlock()
+		if hooks.wait(phase, taskIDs) {
+			return true
+		}
+		stop(func() {
+			if hooks.abort != nil {
+				hooks.abort(phase, taskIDs)
+			}
+		})
+		return false
+	}
+
+	finishNode := func(phase executionPhase, ok bool) {
+		state := states[phase.key]
+		state.mu.Lock()
+		if !ok {
+			state.failed = true
+		}
+		state.remaining--
+		last := state.remaining == 0
+		failed := state.failed
+		state.mu.Unlock()
+		if !last {
+			return
+		}
+		if !failed && hooks.phaseDone != nil && !hooks.phaseDone(phase) {
+			failed = true
+			stop(nil)
+		}
+		state.ok = !failed
+		close(state.done)
+	}
+
+	var wg sync.WaitGroup
+	for _, phase := range phases {
+		for _, ap := range phase.agents {
+			wg.Add(1)
+			go func(phase executionPhase, ap agentPriority) {
+				defer wg.Done()
+				finishNode(phase, runNode(phase, ap))
+			}(phase, ap)
+		}
+	}
+	wg.Wait()
+
+	for _, state := range states {
+		if !state.ok {
+			return false
+		}
+	}
+	return true
+}
+
+// executeTaskGraph is executePhasedTasks for full builds: each phase agent's
+// task starts as soon as the phases it depends on are done, so frontend and
+// data work overlap and frontend work does not hold up the backend. The
+// integration preflight still runs between backend and integration, and the
+// build's current phase only moves forward.
+func (am *AgentManager) executeTaskGraph(build *Build, description string, phases []executionPhase) {
+	phaseTotal := countActiveExecutionPhases(phases)
+	ordinals := make(map[string]int, phaseTotal)
+	announced := make(map[string]*sync.Once, phaseTotal)
+	for _, phase := range phases {
+		if len(phase.agents) == 0 {
+			continue
+		}
+		ordinals[phase.key] = len(ordinals) + 1
+		announced[phase.key] = &sync.Once{}
+	}
+
+	build.mu.Lock()
+	build.TaskGraphRunning = true
+	maxAgents := build.MaxAgents
+	build.mu.Unlock()
+	defer func() {
+		build.mu.Lock()
+		build.TaskGraphRunning = false
+		build.mu.Unlock()
+	}()
+
+	var snapshotMu sync.Mutex
+	latestOrdinal := 0
+	limit := taskGraphConcurrency(maxAgents)
+	log.Printf("Build %s: running %d phases as a task graph (max %d concurrent tasks)", build.ID, phaseTotal, limit)
+
+	completed := runTaskGraph(phases, limit, taskGraphHooks{
+		start: func(phase executionPhase, ap agentPriority, dependencies []string) []string {
+			announced[phase.key].Do(func() {
+				now := time.Now()
+				snapshotMu.Lock()
+				if ordinals[phase.key] > latestOrdinal {
+					latestOrdinal = ordinals[phase.key]
+					setBuildPhaseSnapshot(build, phase, now)
+				}
+				snapshotMu.Unlock()
+				am.broadcastExecutionPhaseStart(build, phase, ordinals[phase.key], phaseTotal, now)
+			})
+			taskIDs := am.assignPhaseAgentsWithDependencies(build, []agentPriority{ap}, description, dependencies)
+			am.persistBuildSnapshot(build, nil)
+			return taskIDs
+		},
+		wait: func(phase executionPhase, taskIDs []string) bool {
+			return am.waitForPhaseCompletion(build, taskIDs)
+		},
+		abort: func(phase executionPhase, taskIDs []string) {
+			log.Printf("Build %s: Phase %s aborted (build cancelled or timed out)", build.ID, phase.name)
+			am.failBuildOnPhaseAbort(build, phase.name, phase.status, taskIDs)
+		},
+		phaseDone: func(phase executionPhase) bool {
+			if phase.key == "backend_services" || phase.key == "parallel_core" {
+				if !am.runIntegrationPreflightRecovery(build) {
+					return false
+				}
+			}
+			am.broadcastExecutionPhaseComplete(build, phase)
+			return true
+		},
+	})
+	if !completed {
+		return
+	}
+
+	build.mu.Lock()
+	build.TaskGraphRunning = false
+	build.mu.Unlock()
+	am.finalizePhasedPipeline(build)
+}
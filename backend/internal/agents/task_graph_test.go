@@ -0,0 +1,164 @@
+package agents
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sequentialTestPhases(t *testing.T, frontendCount int) []executionPhase {
+	t.Helper()
+	t.Setenv("APEX_PARALLEL_MID_PHASE", "false")
+	frontend := make([]agentPriority, 0, frontendCount)
+	for i := 0; i < frontendCount; i++ {
+		frontend = append(frontend, makeAgents(RoleFrontend)...)
+	}
+	return buildExecutionPhases(
+		makeAgents(RoleArchitect), frontend, makeAgents(RoleDatabase),
+		makeAgents(RoleBackend), makeAgents(RoleTesting), makeAgents(RoleReviewer),
+	)
+}
+
+func TestResolveTaskGraphDependenciesSkipsEmptyPhases(t *testing.T) {
+	t.Setenv("APEX_PARALLEL_MID_PHASE", "false")
+	phases := buildExecutionPhases(makeAgents(RoleArchitect), makeAgents(RoleFrontend), nil, makeAgents(RoleBackend), nil, makeAgents(RoleReviewer))
+
+	deps := resolveTaskGraphDependencies(phases)
+	want := map[string][]string{
+		"architecture":     nil,
+		"frontend_ui":      {"architecture"},
+		"backend_services": {"architecture"},
+		"review":           {"architecture", "frontend_ui", "backend_services"},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("dependencies = %v, want %v", deps, want)
+	}
+}
+
+func TestRunTaskGraphOverlapsIndependentPhases(t *testing.T) {
+	phases := sequentialTestPhases(t, 1)
+
+	var mu sync.Mutex
+	taskPhase := map[string]string{}
+	started := map[string][]string{}
+	backendStarted := make(chan struct{})
+	frontendOverlapped := false
+
+	ok := runTaskGraph(phases, 0, taskGraphHooks{
+		start: func(phase executionPhase, ap agentPriority, dependencies []string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			id := phase.key + "-task"
+			taskPhase[id] = phase.key
+			for _, dep := range dependencies {
+				started[phase.key] = append(started[phase.key], taskPhase[dep])
+			}
+			if phase.key == "backend_services" {
+				close(backendStarted)
+			}
+			return []string{id}
+		},
+		wait: func(phase executionPhase, taskIDs []string) bool {
+			if phase.key == "frontend_ui" {
+				select {
+				case <-backendStarted:
+					frontendOverlapped = true
+				case <-time.After(2 * time.Second):
+				}
+			}
+			return true
+		},
+	})
+	if !ok {
+		t.Fatal("graph should complete")
+	}
+	if !frontendOverlapped {
+		t.Fatal("backend should start while frontend work is still running")
+	}
+	if got := started["backend_services"]; !reflect.DeepEqual(got, []string{"architecture", "data_foundation"}) {
+		t.Fatalf("backend task dependencies = %v", got)
+	}
+	if got := started["integration"]; !reflect.DeepEqual(got, []string{"frontend_ui", "data_foundation", "backend_services"}) {
+		t.Fatalf("integration task dependencies = %v", got)
+	}
+}
+
+func TestRunTaskGraphRespectsConcurrencyLimit(t *testing.T) {
+	phases := sequentialTestPhases(t, 3)
+
+	var mu sync.Mutex
+	inFlight, peak, startedCount := 0, 0, 0
+	ok := runTaskGraph(phases, 2, taskGraphHooks{
+		start: func(phase executionPhase, ap agentPriority, dependencies []string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			inFlight++
+			startedCount++
+			if inFlight > peak {
+				peak = inFlight
+			}
+			return []string{phase.key}
+		},
+		wait: func(phase executionPhase, taskIDs []string) bool {
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return true
+		},
+	})
+	if !ok || startedCount != 8 {
+		t.Fatalf("ok=%v started=%d, want every task to run", ok, startedCount)
+	}
+	if peak != 2 {
+		t.Fatalf("peak in-flight tasks = %d, want the limit of 2", peak)
+	}
+	if taskGraphConcurrency(6) != 5 || taskGraphConcurrency(1) != 1 || taskGraphConcurrency(0) != 0 {
+		t.Fatal("concurrency should exclude the lead and treat 0 as unbounded")
+	}
+}
+
+func TestRunTaskGraphStopsOnFailure(t *testing.T) {
+	phases := sequentialTestPhases(t, 1)
+
+	var mu sync.Mutex
+	var started, aborted []string
+	var completed []string
+	ok := runTaskGraph(phases, 0, taskGraphHooks{
+		start: func(phase executionPhase, ap agentPriority, dependencies []string) []string {
+			mu.Lock()
+			started = append(started, phase.key)
+			mu.Unlock()
+			return []string{phase.key}
+		},
+		wait: func(phase executionPhase, taskIDs []string) bool {
+			return phase.key != "backend_services"
+		},
+		abort: func(phase executionPhase, taskIDs []string) {
+			aborted = append(aborted, taskIDs...)
+		},
+		phaseDone: func(phase executionPhase) bool {
+			mu.Lock()
+			completed = append(completed, phase.key)
+			mu.Unlock()
+			return true
+		},
+	})
+	if ok {
+		t.Fatal("graph should report failure")
+	}
+	if !reflect.DeepEqual(aborted, []string{"backend_services"}) {
+		t.Fatalf("aborted = %v, want the failed backend task only", aborted)
+	}
+	for _, key := range started {
+		if key == "integration" || key == "review" {
+			t.Fatalf("%s started after backend failed: %v", key, started)
+		}
+	}
+	for _, key := range completed {
+		if key == "backend_services" {
+			t.Fatalf("failed phase reported complete: %v", completed)
+		}
+	}
+}
@@ -9,6 +9,9 @@ import (
 
 	"apex-build/internal/ai"
 	"apex-build/internal/architecture"
+	"apex-build/internal/codestandards"
+	"apex-build/internal/envcheck"
+	"apex-build/internal/envlock"
 	"apex-build/internal/mobile"
 )
 
@@ -145,6 +148,7 @@ const (
 	TaskReview         TaskType = "review"          // Review generated code
 	TaskFix            TaskType = "fix"             // Fix issues found
 	TaskDeploy         TaskType = "deploy"          // Configure deployment
+	TaskDockerize      TaskType = "dockerize"       // Generate a production Dockerfile verified by an image build
 )
 
 // TaskStatus represents the state of a task
@@ -285,6 +289,55 @@ type BuildPermissionRequest struct {
 	ResolvedAt      *time.Time                   `json:"resolved_at,omitempty"`
 }
 
+// BuildApprovalCheckpoint names a point in the pipeline where a build can be
+// configured to stop and wait for the user's sign-off.
+type BuildApprovalCheckpoint string
+
+const (
+	ApprovalCheckpointPlan   BuildApprovalCheckpoint = "plan"   // after planning, before phase tasks are queued
+	ApprovalCheckpointDeploy BuildApprovalCheckpoint = "deploy" // after generation, before final validation and preview/deploy handoff
+)
+
+type BuildApprovalTimeoutAction string
+
+const (
+	ApprovalTimeoutApprove BuildApprovalTimeoutAction = "approve"
+	ApprovalTimeoutReject  BuildApprovalTimeoutAction = "reject"
+)
+
+// BuildApprovalGate configures one approval checkpoint for a build
+type BuildApprovalGate struct {
+	Checkpoint     BuildApprovalCheckpoint    `json:"checkpoint"`
+	TimeoutSeconds int                        `json:"timeout_seconds,omitempty"`
+	OnTimeout      BuildApprovalTimeoutAction `json:"on_timeout,omitempty"`
+}
+
+type BuildApprovalRequestStatus string
+
+const (
+	ApprovalRequestPending  BuildApprovalRequestStatus = "pending"
+	ApprovalRequestApproved BuildApprovalRequestStatus = "approved"
+	ApprovalRequestRejected BuildApprovalRequestStatus = "rejected"
+	ApprovalRequestTimedOut BuildApprovalRequestStatus = "timed_out"
+)
+
+// BuildApprovalRequest is one pause at an approval gate. A timed-out request
+// continues or stops the build according to its OnTimeout action.
+type BuildApprovalRequest struct {
+	ID             string                     `json:"id"`
+	Checkpoint     BuildApprovalCheckpoint    `json:"checkpoint"`
+	Status         BuildApprovalRequestStatus `json:"status"`
+	Title          string                     `json:"title"`
+	Summary        string                     `json:"summary,omitempty"`
+	OnTimeout      BuildApprovalTimeoutAction `json:"on_timeout"`
+	PlanEdited     bool                       `json:"plan_edited,omitempty"`
+	ResolutionNote string                     `json:"resolution_note,omitempty"`
+	ResolvedBy     string                     `json:"resolved_by,omitempty"` // user or timeout
+	RequestedAt    time.Time                  `json:"requested_at"`
+	ExpiresAt      time.Time                  `json:"expires_at"`
+	ResolvedAt     *time.Time                 `json:"resolved_at,omitempty"`
+}
+
 type BuildApprovalEventStatus string
 
 const (
@@ -313,9 +366,11 @@ type BuildInteractionState struct {
 	WaitingForUser     bool                       `json:"waiting_for_user,omitempty"`
 	Paused             bool                       `json:"paused,omitempty"`
 	PauseReason        string                     `json:"pause_reason,omitempty"`
+	ParkedResults      int                        `json:"parked_results,omitempty"`
 	PermissionRules    []BuildPermissionRule      `json:"permission_rules,omitempty"`
 	PermissionRequests []BuildPermissionRequest   `json:"permission_requests,omitempty"`
 	ApprovalEvents     []BuildApprovalEvent       `json:"approval_events,omitempty"`
+	ApprovalRequests   []BuildApprovalRequest     `json:"approval_requests,omitempty"`
 	AttentionRequired  bool                       `json:"attention_required,omitempty"`
 }
 
@@ -454,6 +509,14 @@ type BuildSnapshotState struct {
 	RestoreContext         *BuildRestoreContext             `json:"restore_context,omitempty"`
 	Orchestration          *BuildOrchestrationState         `json:"orchestration,omitempty"`
 	ArchitectureReferences *architecture.ReferenceTelemetry `json:"architecture_references,omitempty"`
+	AgentTelemetry         map[string]AgentTelemetrySummary `json:"agent_telemetry,omitempty"`
+	CodingStandards        *codestandards.Report            `json:"coding_standards,omitempty"`
+	FileConfidence         *FileConfidenceReport            `json:"file_confidence,omitempty"`
+	ConsensusRounds        []ConsensusRound                 `json:"consensus_rounds,omitempty"`
+	ModelTaskStats         []ModelTaskStat                  `json:"model_task_stats,omitempty"`
+	CostOptimization       *CostOptimizationReport          `json:"cost_optimization,omitempty"`
+	EnvironmentDrift       []envlock.Drift                  `json:"environment_drift,omitempty"`
+	EnvVarCheck            *envcheck.Report                 `json:"env_var_check,omitempty"`
 }
 
 type BuildRestoreContext struct {
@@ -474,6 +537,7 @@ type BuildRestoreContext struct {
 	MaxRetries                  int                       `json:"max_retries,omitempty"`
 	MaxRequests                 int                       `json:"max_requests,omitempty"`
 	MaxTokensPerRequest         int                       `json:"max_tokens_per_request,omitempty"`
+	FileBudget                  int                       `json:"file_budget,omitempty"`
 	PhasedPipelineComplete      bool                      `json:"phased_pipeline_complete,omitempty"`
 	DiffMode                    bool                      `json:"diff_mode,omitempty"`
 	RoleAssignments             map[string]string         `json:"role_assignments,omitempty"`
@@ -486,6 +550,11 @@ type BuildRestoreContext struct {
 	MobileReleaseLevel          mobile.MobileReleaseLevel `json:"mobile_release_level,omitempty"`
 	MobileCapabilities          []mobile.MobileCapability `json:"mobile_capabilities,omitempty"`
 	MobileAppSpec               *mobile.MobileAppSpec     `json:"mobile_app_spec,omitempty"`
+	I18n                        *BuildI18nOptions         `json:"i18n,omitempty"`
+	ApprovalGates               []BuildApprovalGate       `json:"approval_gates,omitempty"`
+	AgentProfiles               []AgentProfile            `json:"agent_profiles,omitempty"`
+	CodingStandards             *codestandards.Policy     `json:"coding_standards,omitempty"`
+	ModelPreferences            ai.ModelPreferences       `json:"byok_model_preferences,omitempty"`
 }
 
 // Build represents an entire app-building session
@@ -511,6 +580,11 @@ type Build struct {
 	MobileReleaseLevel  mobile.MobileReleaseLevel `json:"mobile_release_level,omitempty"`
 	MobileCapabilities  []mobile.MobileCapability `json:"mobile_capabilities,omitempty"`
 	MobileAppSpec       *mobile.MobileAppSpec     `json:"mobile_app_spec,omitempty"`
+	I18n                *BuildI18nOptions         `json:"i18n,omitempty"`
+	ApprovalGates       []BuildApprovalGate       `json:"approval_gates,omitempty"`
+	AgentProfiles       []AgentProfile            `json:"agent_profiles,omitempty"`
+	CodingStandards     *codestandards.Policy     `json:"coding_standards,omitempty"`
+	ModelPreferences    ai.ModelPreferences       `json:"byok_model_preferences,omitempty"` // BYOK per-capability models, captured at creation
 	Plan                *BuildPlan                `json:"plan,omitempty"`
 	Agents              map[string]*Agent         `json:"agents"`
 	Tasks               []*Task                   `json:"tasks"`
@@ -521,6 +595,7 @@ type Build struct {
 	MaxRetries                  int                   `json:"max_retries,omitempty"`
 	MaxRequests                 int                   `json:"max_requests,omitempty"`
 	MaxTokensPerRequest         int                   `json:"max_tokens_per_request,omitempty"`
+	FileBudget                  int                   `json:"file_budget,omitempty"` // max distinct files; 0 = unlimited
 	RequestsUsed                int                   `json:"requests_used,omitempty"`
 	ReadinessRecoveryAttempts   int                   `json:"readiness_recovery_attempts,omitempty"`
 	PreviewVerificationAttempts int                   `json:"preview_verification_attempts,omitempty"`
@@ -529,6 +604,7 @@ type Build struct {
 	CompileValidationRepairs    int                   `json:"compile_validation_repairs,omitempty"`
 	CompileValidationStartedAt  *time.Time            `json:"-"`
 	PhasedPipelineComplete      bool                  `json:"phased_pipeline_complete,omitempty"`
+	TaskGraphRunning            bool                  `json:"-"`                          // executeTaskGraph owns phase progression
 	DiffMode                    bool                  `json:"diff_mode,omitempty"`        // When true, changes require user review before applying
 	RoleAssignments             map[string]string     `json:"role_assignments,omitempty"` // User-specified provider per role category
 	ProviderModelOverrides      map[string]string     `json:"provider_model_overrides,omitempty"`
@@ -541,6 +617,12 @@ type Build struct {
 	CompletedAt                 *time.Time            `json:"completed_at,omitempty"`
 	Error                       string                `json:"error,omitempty"`
 	FinalizationInProgress      bool                  `json:"-"`
+	ParkedTaskResults           []*TaskResult         `json:"-"` // results that arrived while paused, applied on resume
+	RetryBudget                 *ai.RetryBudget       `json:"-"` // rate-limit retry waits; see BuildRetryBudget
+
+	// ModelHistory caches model outcomes from the owner's earlier builds for
+	// budget mode routing. It is loaded on first use.
+	ModelHistory map[string]ModelTaskStat `json:"-"`
 
 	mu sync.RWMutex
 }
@@ -576,6 +658,7 @@ const (
 	PowerBalanced PowerMode = "balanced" // Mid-tier quality/speed balance (Sonnet 4.6, GPT-4.1, Gemini 3 Flash, Grok 3)
 	PowerFast     PowerMode = "fast"     // Cheapest mini tier (Haiku 4.5, GPT-4o-mini, Gemini 2.5 Flash Lite, Grok 3 Mini)
 	PowerAuto     PowerMode = "auto"     // OpenRouter dispatcher — auto-selects best model via GPT-5.5
+	PowerBudget   PowerMode = "budget"   // Cheapest model with a success record per role/task type, escalating after failures
 )
 
 // CreditMultiplier returns the credit usage multiplier for a power mode
@@ -621,6 +704,8 @@ type BuildPlan struct {
 	WorkOrders           []BuildWorkOrder       `json:"work_orders,omitempty"`
 	APIContract          *BuildAPIContract      `json:"api_contract,omitempty"`
 	Preflight            []BuildPreflightCheck  `json:"preflight,omitempty"`
+	FileBudget           int                    `json:"file_budget,omitempty"`
+	TrimmedFiles         []string               `json:"trimmed_files,omitempty"` // planned files cut to fit FileBudget
 	EstimatedTime        time.Duration          `json:"estimated_time"`
 	CreatedAt            time.Time              `json:"created_at"`
 }
@@ -794,6 +879,7 @@ const (
 	WSAgentGenerating       WSMessageType = "agent:generating"
 	WSAgentRetrying         WSMessageType = "agent:retrying"
 	WSAgentProviderSwitched WSMessageType = "agent:provider_switched"
+	WSAgentTelemetry        WSMessageType = "agent:telemetry"
 	WSFileCreated           WSMessageType = "file:created"
 	WSFileUpdated           WSMessageType = "file:updated"
 	WSCodeGenerated         WSMessageType = "code:generated"
@@ -837,6 +923,15 @@ const (
 	WSBuildUserInputResolved WSMessageType = "build:user-input-resolved"
 	WSBuildPermissionRequest WSMessageType = "build:permission-request"
 	WSBuildPermissionUpdate  WSMessageType = "build:permission-update"
+	WSBuildApprovalRequest   WSMessageType = "build:approval-request"
+	WSBuildApprovalUpdate    WSMessageType = "build:approval-update"
+	WSBuildPlanUpdated       WSMessageType = "build:plan-updated"
+	WSBuildCodingStandards   WSMessageType = "build:standards:report"
+	WSBuildProviderCircuit   WSMessageType = "build:provider:circuit"
+	WSBuildScopeTrimmed      WSMessageType = "build:scope:trimmed"
+	WSBuildHookStarted       WSMessageType = "build:hook:started"
+	WSBuildHookOutput        WSMessageType = "build:hook:output"
+	WSBuildHookFinished      WSMessageType = "build:hook:finished"
 
 	// Glass-box orchestration telemetry events. These are additive visibility
 	// events derived from real orchestration artifacts; existing clients may
@@ -870,7 +965,7 @@ type BuildRequest struct {
 	WireframeImage         string                    `json:"wireframe_image,omitempty"`
 	WireframeDescription   string                    `json:"wireframe_description,omitempty"`
 	Mode                   BuildMode                 `json:"mode"`
-	PowerMode              PowerMode                 `json:"power_mode,omitempty"`    // max, balanced, fast — controls model quality
+	PowerMode              PowerMode                 `json:"power_mode,omitempty"`    // max, balanced, fast, budget — controls model quality
 	ProviderMode           string                    `json:"provider_mode,omitempty"` // platform or byok
 	RequirePreviewReady    bool                      `json:"require_preview_ready,omitempty"`
 	ProjectName            string                    `json:"project_name,omitempty"`
@@ -882,9 +977,13 @@ type BuildRequest struct {
 	MobileCapabilities     []mobile.MobileCapability `json:"mobile_capabilities,omitempty"`
 	MobileDependencyPolicy string                    `json:"mobile_dependency_policy,omitempty"`
 	MobileAppSpec          *mobile.MobileAppSpec     `json:"mobile_app_spec,omitempty"`
+	I18n                   *BuildI18nOptions         `json:"i18n,omitempty"`             // Optional: scaffold locale files, a translation framework, and a locale switcher
+	ApprovalGates          []BuildApprovalGate       `json:"approval_gates,omitempty"`   // Optional: pause for user sign-off at the plan and/or deploy checkpoints
+	AgentProfiles          []AgentProfile            `json:"agent_profiles,omitempty"`   // Optional: per-role instructions, provider and constraints; merged over installed marketplace agents
 	DiffMode               bool                      `json:"diff_mode,omitempty"`        // When true, proposed changes require user approval
 	RoleAssignments        map[string]string         `json:"role_assignments,omitempty"` // Optional: user-specified provider per role category (architect→claude, coder→gpt4, etc.)
 	ProviderModelOverrides map[string]string         `json:"provider_model_overrides,omitempty"`
+	MaxFiles               int                       `json:"max_files,omitempty"` // Optional: tighter file budget than the plan tier allows
 	RequestID              string                    `json:"-"`
 	OperationID            string                    `json:"-"`
 }
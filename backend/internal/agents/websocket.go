@@ -5,6 +5,7 @@ package agents
 import (
 	"apex-build/internal/applog"
 	apihandlers "apex-build/internal/handlers"
+	wsqueue "apex-build/internal/websocket"
 	"encoding/json"
 	"errors"
 	"log"
@@ -37,6 +38,10 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin:     apihandlers.AllowedWebSocketOrigin,
 }
 
+// sendQueueServiceInterval is how often blocked connection queues are
+// retried and slow connections disconnected
+const sendQueueServiceInterval = 100 * time.Millisecond
+
 // WSHub manages WebSocket connections for builds
 type WSHub struct {
 	connections map[string]map[*WSConnection]bool
@@ -44,21 +49,27 @@ type WSHub struct {
 	register    chan *registerRequest
 	unregister  chan *WSConnection
 	manager     *AgentManager
+	metrics     wsqueue.QueueMetrics
+	slowTimeout time.Duration
 	mu          sync.RWMutex
 }
 
-// WSConnection represents a single WebSocket connection
+// WSConnection represents a single WebSocket connection. Everything sent to
+// it goes through queue, which holds messages while send is full and drops
+// progress before anything a client cannot do without.
 type WSConnection struct {
 	hub       *WSHub
 	conn      *websocket.Conn
 	buildID   string
 	userID    uint
 	send      chan []byte
+	queue     *wsqueue.SendQueue
 	closeOnce sync.Once
 }
 
 type broadcastMessage struct {
 	buildID string
+	msgType string
 	message []byte
 }
 
@@ -75,13 +86,23 @@ func NewWSHub(manager *AgentManager) *WSHub {
 		register:    make(chan *registerRequest),
 		unregister:  make(chan *WSConnection),
 		manager:     manager,
+		slowTimeout: wsqueue.SlowClientTimeout,
 	}
 	go hub.run()
 	return hub
 }
 
+// SetQueueMetrics reports dropped messages, queue depth and slow connection
+// disconnects.
+func (h *WSHub) SetQueueMetrics(m wsqueue.QueueMetrics) {
+	h.metrics = m
+}
+
 // run handles WebSocket events
 func (h *WSHub) run() {
+	ticker := time.NewTicker(sendQueueServiceInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case req := <-h.register:
@@ -117,23 +138,83 @@ func (h *WSHub) run() {
 
 		case msg := <-h.broadcast:
 			h.mu.RLock()
-			conns := h.connections[msg.buildID]
+			conns := make([]*WSConnection, 0, len(h.connections[msg.buildID]))
+			for conn := range h.connections[msg.buildID] {
+				conns = append(conns, conn)
+			}
 			h.mu.RUnlock()
 
-			for conn := range conns {
-				select {
-				case conn.send <- msg.message:
-				default:
-					h.mu.Lock()
-					conn.closeSend()
-					delete(h.connections[msg.buildID], conn)
-					h.mu.Unlock()
-				}
+			for _, conn := range conns {
+				conn.enqueue(msg.msgType, msg.message)
 			}
+
+		case <-ticker.C:
+			h.serviceSendQueues()
 		}
 	}
 }
 
+// enqueue sends a frame through the connection's queue
+func (c *WSConnection) enqueue(msgType string, data []byte) {
+	for _, dropped := range c.queue.Send(c.send, msgType, data) {
+		c.hub.recordDrop(dropped, wsqueue.DropReasonQueueFull)
+	}
+}
+
+// serviceSendQueues retries connections whose send buffer was full and
+// disconnects those that have stayed full past the slow client timeout
+func (h *WSHub) serviceSendQueues() {
+	h.mu.RLock()
+	conns := make([]*WSConnection, 0)
+	for _, buildConns := range h.connections {
+		for conn := range buildConns {
+			conns = append(conns, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	queued := 0
+	for _, conn := range conns {
+		blocked := conn.queue.Flush(conn.send)
+		if blocked > h.slowTimeout {
+			h.disconnectSlow(conn, blocked)
+			continue
+		}
+		queued += conn.queue.Len()
+	}
+	if h.metrics != nil {
+		h.metrics.SetWebSocketQueuedMessages("build", queued)
+	}
+}
+
+// disconnectSlow closes a connection whose send buffer has stayed full and
+// counts its queued messages as dropped
+func (h *WSHub) disconnectSlow(conn *WSConnection, blockedFor time.Duration) {
+	h.mu.Lock()
+	if conns, ok := h.connections[conn.buildID]; ok {
+		delete(conns, conn)
+	}
+	conn.closeSend()
+	h.mu.Unlock()
+
+	dropped := conn.queue.Drain()
+	for _, msgType := range dropped {
+		h.recordDrop(msgType, wsqueue.DropReasonDisconnected)
+	}
+	if h.metrics != nil {
+		h.metrics.RecordWebSocketSlowClient()
+	}
+	log.Printf("Disconnecting slow WebSocket client %d from build %s: send buffer full for %s, %d messages dropped",
+		conn.userID, conn.buildID, blockedFor.Round(time.Millisecond), len(dropped))
+}
+
+// recordDrop counts a message dropped from a connection queue
+func (h *WSHub) recordDrop(msgType, reason string) {
+	if h.metrics != nil {
+		h.metrics.RecordWebSocketDrop(msgType, reason)
+	}
+}
+
 func (c *WSConnection) closeSend() {
 	c.closeOnce.Do(func() {
 		close(c.send)
@@ -150,6 +231,7 @@ func (h *WSHub) Broadcast(buildID string, msg *WSMessage) {
 
 	h.broadcast <- &broadcastMessage{
 		buildID: buildID,
+		msgType: string(msg.Type),
 		message: data,
 	}
 }
@@ -207,6 +289,7 @@ func (h *WSHub) HandleWebSocket(c *gin.Context) {
 		buildID: buildID,
 		userID:  uid,
 		send:    make(chan []byte, 256),
+		queue:   wsqueue.NewSendQueue(wsqueue.ClientQueueLimit),
 	}
 
 	// Register connection
@@ -240,11 +323,7 @@ func (h *WSHub) HandleWebSocket(c *gin.Context) {
 					log.Printf("Failed to marshal WebSocket message for build %s: %v", buildID, err)
 					continue
 				}
-				select {
-				case wsConn.send <- data:
-				default:
-					log.Printf("WebSocket send buffer full for build %s, dropping message", buildID)
-				}
+				wsConn.enqueue(string(msg.Type), data)
 			}
 		}
 	}()
@@ -263,10 +342,7 @@ func (h *WSHub) HandleWebSocket(c *gin.Context) {
 		},
 	}
 	if data, err := json.Marshal(confirmMsg); err == nil {
-		select {
-		case wsConn.send <- data:
-		default:
-		}
+		wsConn.enqueue(string(confirmMsg.Type), data)
 	}
 
 	// Start connection handlers
@@ -617,12 +693,8 @@ func (c *WSConnection) sendBuildState() {
 		return
 	}
 
-	select {
-	case c.send <- data:
-		log.Printf("Sent build state for %s (%d agents, %d tasks, %d files)", c.buildID, len(agentsList), len(tasksList), len(allFiles))
-	default:
-		log.Printf("Failed to send build state for %s: channel full", c.buildID)
-	}
+	c.enqueue(string(state.Type), data)
+	log.Printf("Sent build state for %s (%d agents, %d tasks, %d files)", c.buildID, len(agentsList), len(tasksList), len(allFiles))
 }
 
 // GetConnectionCount returns the number of active connections for a build
@@ -0,0 +1,69 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	wsqueue "apex-build/internal/websocket"
+)
+
+type recordingQueueMetrics struct {
+	drops       map[string]int
+	queued      map[string]int
+	slowClients int
+}
+
+func (m *recordingQueueMetrics) RecordWebSocketDrop(msgType, reason string) {
+	m.drops[msgType+"/"+reason]++
+}
+
+func (m *recordingQueueMetrics) SetWebSocketQueuedMessages(hub string, count int) {
+	m.queued[hub] = count
+}
+
+func (m *recordingQueueMetrics) RecordWebSocketSlowClient() {
+	m.slowClients++
+}
+
+func TestWSHubQueuesForFullConnectionAndKeepsApprovals(t *testing.T) {
+	metrics := &recordingQueueMetrics{drops: map[string]int{}, queued: map[string]int{}}
+	hub := &WSHub{connections: map[string]map[*WSConnection]bool{}, metrics: metrics, slowTimeout: time.Hour}
+	conn := &WSConnection{hub: hub, buildID: "b1", userID: 1, send: make(chan []byte, 1), queue: wsqueue.NewSendQueue(2)}
+	hub.connections["b1"] = map[*WSConnection]bool{conn: true}
+	conn.send <- []byte("backlog")
+
+	conn.enqueue(string(WSBuildProgress), []byte("progress"))
+	conn.enqueue(string(WSBuildApprovalRequest), []byte("approval"))
+	conn.enqueue(string(WSBuildCompleted), []byte("completed"))
+
+	// The full buffer kept the connection open and only progress was dropped
+	hub.serviceSendQueues()
+	if !hub.connections["b1"][conn] {
+		t.Fatal("connection with a full buffer was closed")
+	}
+	if metrics.drops["build:progress/queue_full"] != 1 || metrics.queued["build"] != 2 {
+		t.Fatalf("unexpected metrics: drops=%v queued=%v", metrics.drops, metrics.queued)
+	}
+	<-conn.send
+	hub.serviceSendQueues()
+	if got := string(<-conn.send); got != "approval" {
+		t.Fatalf("expected the approval request next, got %q", got)
+	}
+
+	// A connection that stays full past the timeout is disconnected
+	hub.slowTimeout = time.Millisecond
+	conn.send <- []byte("backlog")
+	hub.serviceSendQueues()
+	time.Sleep(5 * time.Millisecond)
+	hub.serviceSendQueues()
+	if hub.connections["b1"][conn] || metrics.slowClients != 1 {
+		t.Fatalf("slow connection not disconnected: slow=%d", metrics.slowClients)
+	}
+	if metrics.drops["build:completed/slow_client"] != 1 {
+		t.Fatalf("expected the queued completion counted as dropped: %v", metrics.drops)
+	}
+	<-conn.send
+	if _, open := <-conn.send; open {
+		t.Fatal("send channel left open")
+	}
+}
@@ -51,7 +51,7 @@ func compileTaskWorkOrder(task *Task) compiledWorkOrder {
 		compiled.TaskShape = TaskShapeContract
 	case TaskReview, TaskTest:
 		compiled.TaskShape = TaskShapeVerification
-	case TaskDeploy:
+	case TaskDeploy, TaskDockerize:
 		compiled.TaskShape = TaskShapeIntegration
 	case TaskFix:
 		compiled.TaskShape = TaskShapeRepair
@@ -59,7 +59,7 @@ func compileTaskWorkOrder(task *Task) compiledWorkOrder {
 		compiled.TaskShape = TaskShapeRepair
 	}
 
-	if task.Type == TaskArchitecture || task.Type == TaskDeploy {
+	if task.Type == TaskArchitecture || task.Type == TaskDeploy || task.Type == TaskDockerize {
 		compiled.RiskLevel = RiskHigh
 	}
 	if task.RetryCount >= 2 && compiled.RiskLevel != RiskCritical {
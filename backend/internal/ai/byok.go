@@ -54,14 +54,30 @@ func (m *BYOKManager) UserCanUseBYOK(userID uint) bool {
 }
 
 // modelOverrideClient applies a default model when the request doesn't specify one.
-// This is used for BYOK model preferences.
+// This is used for BYOK model preferences: a per-capability preference for the
+// request's ModelScope wins over the key's single default model.
 type modelOverrideClient struct {
-	base         AIClient
-	defaultModel string
+	base             AIClient
+	defaultModel     string
+	capabilityModels map[string]string
+}
+
+func newModelOverrideClient(base AIClient, key models.UserAPIKey) AIClient {
+	if key.ModelPreference == "" && len(key.CapabilityModels) == 0 {
+		return base
+	}
+	return &modelOverrideClient{base: base, defaultModel: key.ModelPreference, capabilityModels: key.CapabilityModels}
 }
 
 func (m *modelOverrideClient) Generate(ctx context.Context, req *AIRequest) (*AIResponse, error) {
-	if req == nil || m.defaultModel == "" || req.Model != "" {
+	model := ""
+	if req != nil && req.Model == "" {
+		model = CapabilityModel(m.capabilityModels, req.ModelScope)
+		if model == "" {
+			model = m.defaultModel
+		}
+	}
+	if model == "" {
 		resp, err := m.base.Generate(ctx, req)
 		if resp != nil && req != nil && req.Model != "" {
 			if resp.Metadata == nil {
@@ -76,7 +92,7 @@ func (m *modelOverrideClient) Generate(ctx context.Context, req *AIRequest) (*AI
 
 	// Copy request to avoid mutating caller state
 	reqCopy := *req
-	reqCopy.Model = m.defaultModel
+	reqCopy.Model = model
 	resp, err := m.base.Generate(ctx, &reqCopy)
 	if resp != nil {
 		if resp.Metadata == nil {
@@ -197,6 +213,17 @@ func (m *BYOKManager) GetKeyForProject(userID uint, provider string, projectID u
 	return &globalKey, nil
 }
 
+// ActiveKeyID returns the ID of the user's active key for provider, or nil
+// when requests to it run on platform keys
+func (m *BYOKManager) ActiveKeyID(userID uint, provider string) *uint {
+	var key models.UserAPIKey
+	if err := m.db.Select("id").Where("user_id = ? AND provider = ? AND is_active = ? AND deleted_at IS NULL", userID, provider, true).
+		First(&key).Error; err != nil {
+		return nil
+	}
+	return &key.ID
+}
+
 // DeleteKey removes a user's API key for a provider
 func (m *BYOKManager) DeleteKey(userID uint, provider string) error {
 	return m.db.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.UserAPIKey{}).Error
@@ -388,40 +415,20 @@ func (m *BYOKManager) GetRouterForUser(userID uint) (*AIRouter, bool, error) {
 
 		switch AIProvider(key.Provider) {
 		case ProviderClaude:
-			client := AIClient(NewClaudeClient(apiKey))
-			if key.ModelPreference != "" {
-				client = &modelOverrideClient{base: client, defaultModel: key.ModelPreference}
-			}
-			clients[ProviderClaude] = client
+			clients[ProviderClaude] = newModelOverrideClient(NewClaudeClient(apiKey), key)
 			hasValidClient = true
 		case ProviderGPT4:
-			client := AIClient(NewOpenAIClient(apiKey))
-			if key.ModelPreference != "" {
-				client = &modelOverrideClient{base: client, defaultModel: key.ModelPreference}
-			}
-			clients[ProviderGPT4] = client
+			clients[ProviderGPT4] = newModelOverrideClient(NewOpenAIClient(apiKey), key)
 			hasValidClient = true
 		case ProviderGemini:
-			client := AIClient(NewGeminiClient(apiKey))
-			if key.ModelPreference != "" {
-				client = &modelOverrideClient{base: client, defaultModel: key.ModelPreference}
-			}
-			clients[ProviderGemini] = client
+			clients[ProviderGemini] = newModelOverrideClient(NewGeminiClient(apiKey), key)
 			hasValidClient = true
 		case ProviderGrok:
-			client := AIClient(NewGrokClient(apiKey))
-			if key.ModelPreference != "" {
-				client = &modelOverrideClient{base: client, defaultModel: key.ModelPreference}
-			}
-			clients[ProviderGrok] = client
+			clients[ProviderGrok] = newModelOverrideClient(NewGrokClient(apiKey), key)
 			hasValidClient = true
 		case ProviderOllama:
 			baseURL, ollamaAPIKey := parseOllamaCredential(apiKey)
-			client := AIClient(NewOllamaClient(baseURL, ollamaAPIKey))
-			if key.ModelPreference != "" {
-				client = &modelOverrideClient{base: client, defaultModel: key.ModelPreference}
-			}
-			clients[ProviderOllama] = client
+			clients[ProviderOllama] = newModelOverrideClient(NewOllamaClient(baseURL, ollamaAPIKey), key)
 			hasValidClient = true
 		}
 	}
@@ -480,6 +487,17 @@ func (m *BYOKManager) GetRouterForUser(userID uint) (*AIRouter, bool, error) {
 		rateLimits:   rateLimits,
 		healthCheck:  make(map[AIProvider]bool),
 		healthStatus: make(map[AIProvider]string),
+		regions:      providerRegionsFromEnv(),
+	}
+	// User keys reach the same provider endpoints, so region tags and the
+	// organization region policy carry over from the platform router.
+	if m.platformRouter != nil {
+		m.platformRouter.mu.RLock()
+		for provider, tags := range m.platformRouter.regions {
+			router.regions[provider] = append([]Region(nil), tags...)
+		}
+		router.regionPolicy = m.platformRouter.regionPolicy
+		m.platformRouter.mu.RUnlock()
 	}
 
 	// Mark configured providers as healthy by default for BYOK routers.
@@ -0,0 +1,208 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"apex-build/internal/pricing"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Model scopes for per-capability BYOK model preferences. A build role scope
+// ("build:frontend") wins over the generic "build" scope, which wins over the
+// key's single ModelPreference.
+const (
+	ModelScopeCompletions = "completions"
+	ModelScopeChat        = "chat"
+	ModelScopeBuild       = "build"
+
+	buildRoleScopePrefix = "build:"
+)
+
+// BuildRoleModelScopes lists the agent roles that accept their own model.
+var BuildRoleModelScopes = []string{
+	"planner", "architect", "frontend", "backend", "database",
+	"testing", "devops", "reviewer", "solver", "lead",
+}
+
+// ollamaModelPattern accepts self-hosted Ollama model tags, which cannot be
+// checked against a fixed list.
+var ollamaModelPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._:/-]{0,99}$`)
+
+// BuildRoleModelScope returns the preference scope for an agent role.
+func BuildRoleModelScope(role string) string {
+	return buildRoleScopePrefix + strings.ToLower(strings.TrimSpace(role))
+}
+
+func validModelScope(scope string) bool {
+	switch scope {
+	case ModelScopeCompletions, ModelScopeChat, ModelScopeBuild:
+		return true
+	}
+	if role, ok := strings.CutPrefix(scope, buildRoleScopePrefix); ok {
+		for _, known := range BuildRoleModelScopes {
+			if role == known {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateProviderModel reports whether model is offered by provider.
+// Ollama accepts any well-formed tag because models are self-hosted.
+func ValidateProviderModel(provider, model string) error {
+	if provider == string(ProviderOllama) {
+		if !ollamaModelPattern.MatchString(model) {
+			return fmt.Errorf("invalid ollama model %q", model)
+		}
+		return nil
+	}
+	available, ok := GetAvailableModels()[provider]
+	if !ok {
+		return fmt.Errorf("unknown provider %q", provider)
+	}
+	for _, info := range available {
+		if info.ID == model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q is not available for provider %s", model, provider)
+}
+
+// NormalizeCapabilityModels validates per-capability model preferences for a
+// provider. Empty models are dropped so callers can clear a scope.
+func NormalizeCapabilityModels(provider string, prefs map[string]string) (map[string]string, error) {
+	normalized := make(map[string]string, len(prefs))
+	for scope, model := range prefs {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		model = strings.TrimSpace(model)
+		if !validModelScope(scope) {
+			return nil, fmt.Errorf("unknown model scope %q", scope)
+		}
+		if model == "" {
+			continue
+		}
+		if err := ValidateProviderModel(provider, model); err != nil {
+			return nil, err
+		}
+		normalized[scope] = model
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// ModelPreferences maps provider -> scope -> model for a user's BYOK keys.
+type ModelPreferences map[string]map[string]string
+
+// CapabilityModel picks the preferred model for a scope from a key's
+// preferences, falling back from a build role to "build". It returns "" when
+// no per-capability preference applies.
+func CapabilityModel(prefs map[string]string, scope string) string {
+	if len(prefs) == 0 || scope == "" {
+		return ""
+	}
+	if model := prefs[scope]; model != "" {
+		return model
+	}
+	if strings.HasPrefix(scope, buildRoleScopePrefix) {
+		return prefs[ModelScopeBuild]
+	}
+	return ""
+}
+
+// UpdateCapabilityModels replaces the per-capability model preferences on a
+// user's key for provider.
+func (m *BYOKManager) UpdateCapabilityModels(userID uint, provider string, prefs map[string]string) (map[string]string, error) {
+	normalized, err := NormalizeCapabilityModels(provider, prefs)
+	if err != nil {
+		return nil, err
+	}
+	var key models.UserAPIKey
+	if err := m.db.Where("user_id = ? AND provider = ? AND deleted_at IS NULL", userID, provider).First(&key).Error; err != nil {
+		return nil, fmt.Errorf("no key found for provider %s", provider)
+	}
+	key.CapabilityModels = normalized
+	if err := m.db.Model(&key).Select("capability_models").Updates(&key).Error; err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// CapabilityModelPreferences returns the per-capability preferences of the
+// user's active keys.
+func (m *BYOKManager) CapabilityModelPreferences(userID uint) ModelPreferences {
+	return LoadCapabilityModelPreferences(m.db, userID)
+}
+
+// LoadCapabilityModelPreferences reads the per-capability preferences of a
+// user's active keys. Keys without them are skipped.
+func LoadCapabilityModelPreferences(db *gorm.DB, userID uint) ModelPreferences {
+	if db == nil || userID == 0 {
+		return nil
+	}
+	var keys []models.UserAPIKey
+	if err := db.Select("provider", "capability_models").
+		Where("user_id = ? AND is_active = ? AND deleted_at IS NULL", userID, true).
+		Find(&keys).Error; err != nil {
+		return nil
+	}
+	prefs := make(ModelPreferences)
+	for _, key := range keys {
+		if len(key.CapabilityModels) > 0 {
+			prefs[key.Provider] = key.CapabilityModels
+		}
+	}
+	if len(prefs) == 0 {
+		return nil
+	}
+	return prefs
+}
+
+// ModelCostHint is a model's per-million-token list price, shown next to
+// model choices so users can weigh cost per capability.
+type ModelCostHint struct {
+	InputPer1M  float64 `json:"input_per_1m_usd"`
+	OutputPer1M float64 `json:"output_per_1m_usd"`
+	// Relative places the model among its provider's models: 1 is the
+	// cheapest listed model.
+	Relative float64 `json:"relative"`
+}
+
+// ModelCatalogEntry is a model with its cost hint.
+type ModelCatalogEntry struct {
+	ModelInfo
+	Cost ModelCostHint `json:"cost"`
+}
+
+// GetModelCatalog returns the available models per provider with cost hints.
+func GetModelCatalog() map[string][]ModelCatalogEntry {
+	engine := pricing.Get()
+	catalog := make(map[string][]ModelCatalogEntry)
+	for provider, infos := range GetAvailableModels() {
+		entries := make([]ModelCatalogEntry, 0, len(infos))
+		cheapest := 0.0
+		for _, info := range infos {
+			rates := engine.ModelRates(provider, info.ID)
+			entry := ModelCatalogEntry{ModelInfo: info, Cost: ModelCostHint{InputPer1M: rates.InputPer1M, OutputPer1M: rates.OutputPer1M}}
+			if blended := rates.InputPer1M + rates.OutputPer1M; blended > 0 && (cheapest == 0 || blended < cheapest) {
+				cheapest = blended
+			}
+			entries = append(entries, entry)
+		}
+		if cheapest > 0 {
+			for idx := range entries {
+				blended := entries[idx].Cost.InputPer1M + entries[idx].Cost.OutputPer1M
+				entries[idx].Cost.Relative = math.Round(blended/cheapest*10) / 10
+			}
+		}
+		catalog[provider] = entries
+	}
+	return catalog
+}
@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"context"
 	"testing"
 
 	"apex-build/internal/secrets"
@@ -100,3 +101,58 @@ func TestBYOKRouterWithOllamaKeyDoesNotInheritPlatformProviders(t *testing.T) {
 		t.Fatalf("selectProvider = %s, want %s", selected, ProviderOllama)
 	}
 }
+
+func TestCapabilityModelPreferencesValidateAndApplyPerScope(t *testing.T) {
+	if _, err := NormalizeCapabilityModels("claude", map[string]string{"completions": "gpt-4.1"}); err == nil {
+		t.Fatal("models from another provider should be rejected")
+	}
+	if _, err := NormalizeCapabilityModels("claude", map[string]string{"build:janitor": "claude-haiku-4-5-20251001"}); err == nil {
+		t.Fatal("unknown scopes should be rejected")
+	}
+	prefs, err := NormalizeCapabilityModels("claude", map[string]string{
+		" Completions ":  "claude-haiku-4-5-20251001",
+		"build":          "claude-sonnet-4-6",
+		"build:reviewer": "claude-opus-4-7",
+		"chat":           "",
+	})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if len(prefs) != 3 || prefs[ModelScopeCompletions] != "claude-haiku-4-5-20251001" {
+		t.Fatalf("unexpected normalized prefs %+v", prefs)
+	}
+	if CapabilityModel(prefs, BuildRoleModelScope("reviewer")) != "claude-opus-4-7" ||
+		CapabilityModel(prefs, BuildRoleModelScope("frontend")) != "claude-sonnet-4-6" ||
+		CapabilityModel(prefs, ModelScopeChat) != "" {
+		t.Fatal("role scopes should fall back to build, and unset scopes to nothing")
+	}
+
+	base := &stubProviderClient{provider: ProviderClaude}
+	client := newModelOverrideClient(base, models.UserAPIKey{ModelPreference: "claude-opus-4-7", CapabilityModels: prefs})
+	for _, tc := range []struct {
+		req  AIRequest
+		want string
+	}{
+		{AIRequest{ModelScope: ModelScopeCompletions}, "claude-haiku-4-5-20251001"},
+		{AIRequest{ModelScope: ModelScopeChat}, "claude-opus-4-7"},
+		{AIRequest{ModelScope: ModelScopeCompletions, Model: "claude-sonnet-4-6"}, "claude-sonnet-4-6"},
+	} {
+		req := tc.req
+		if _, err := client.Generate(context.Background(), &req); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if base.lastReq.Model != tc.want {
+			t.Fatalf("scope %q sent model %q, want %q", tc.req.ModelScope, base.lastReq.Model, tc.want)
+		}
+	}
+
+	catalog := GetModelCatalog()
+	if len(catalog["claude"]) != len(GetAvailableModels()["claude"]) {
+		t.Fatal("catalog should list every available model")
+	}
+	for _, entry := range catalog["claude"] {
+		if entry.Cost.InputPer1M <= 0 || entry.Cost.Relative < 1 {
+			t.Fatalf("expected a cost hint for %s, got %+v", entry.ID, entry.Cost)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a provider's circuit breaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects calls until the cooldown elapses.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single probe call through to test recovery.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// errCircuitOpen is returned for calls rejected by an open circuit. The
+// "circuit_open" prefix keeps it out of the transient-retry path.
+var errCircuitOpen = errors.New("circuit_open")
+
+// CircuitBreakerConfig tunes the per-provider circuit breakers.
+type CircuitBreakerConfig struct {
+	Window       int           // recent calls considered when deciding to trip
+	MinCalls     int           // calls required in the window before tripping
+	ErrorRate    float64       // failed share of the window that opens the circuit
+	SlowCall     time.Duration // calls at least this slow count as slow
+	SlowCallRate float64       // slow share of the window that opens the circuit
+	Cooldown     time.Duration // first open period before a half-open probe
+	MaxCooldown  time.Duration // cap for the cooldown, which doubles on failed probes
+}
+
+// DefaultCircuitBreakerConfig returns the breaker settings used by NewAIRouter.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Window:       20,
+		MinCalls:     6,
+		ErrorRate:    0.5,
+		SlowCall:     100 * time.Second,
+		SlowCallRate: 0.8,
+		Cooldown:     30 * time.Second,
+		MaxCooldown:  5 * time.Minute,
+	}
+}
+
+// ProviderCircuitEvent describes a circuit breaker state change.
+type ProviderCircuitEvent struct {
+	Provider   AIProvider   `json:"provider"`
+	From       CircuitState `json:"from"`
+	To         CircuitState `json:"to"`
+	Reason     string       `json:"reason"` // error_rate, slow_calls, cooldown_elapsed, probe_failed, probe_succeeded
+	ErrorClass string       `json:"error_class,omitempty"`
+	RetryAt    *time.Time   `json:"retry_at,omitempty"`
+	At         time.Time    `json:"at"`
+}
+
+// ProviderCircuitStatus is a provider's breaker state and the recent calls
+// behind it. Error classes are RetryReasonCode values.
+type ProviderCircuitStatus struct {
+	State        CircuitState   `json:"state"`
+	Calls        int            `json:"calls"`
+	ErrorRate    float64        `json:"error_rate"`
+	SlowCallRate float64        `json:"slow_call_rate"`
+	AvgLatencyMs int64          `json:"avg_latency_ms"`
+	RecentErrors map[string]int `json:"recent_errors,omitempty"`
+	LastError    string         `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time     `json:"last_error_at,omitempty"`
+	Trips        int            `json:"trips"`
+	TripReason   string         `json:"trip_reason,omitempty"`
+	OpenedAt     *time.Time     `json:"opened_at,omitempty"`
+	RetryAt      *time.Time     `json:"retry_at,omitempty"`
+}
+
+type circuitCall struct {
+	failed  bool
+	slow    bool
+	latency time.Duration
+	class   string
+}
+
+type providerCircuit struct {
+	state       CircuitState
+	calls       []circuitCall
+	next        int
+	openedAt    time.Time
+	cooldown    time.Duration
+	probing     bool
+	trips       int
+	tripReason  string
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// circuitBreakers tracks one breaker per provider. A nil *circuitBreakers
+// permits everything, so routers built without breakers (BYOK routers, tests)
+// behave as before.
+type circuitBreakers struct {
+	mu        sync.Mutex
+	cfg       CircuitBreakerConfig
+	circuits  map[AIProvider]*providerCircuit
+	listeners []func(ProviderCircuitEvent)
+	now       func() time.Time
+}
+
+func newCircuitBreakers(cfg CircuitBreakerConfig) *circuitBreakers {
+	return &circuitBreakers{
+		cfg:      cfg,
+		circuits: make(map[AIProvider]*providerCircuit),
+		now:      time.Now,
+	}
+}
+
+func (b *circuitBreakers) circuitLocked(provider AIProvider) *providerCircuit {
+	circuit, ok := b.circuits[provider]
+	if !ok {
+		circuit = &providerCircuit{state: CircuitClosed, cooldown: b.cfg.Cooldown}
+		b.circuits[provider] = circuit
+	}
+	return circuit
+}
+
+// permits reports whether a call to provider would currently be admitted,
+// without claiming the half-open probe slot. Used during provider selection.
+func (b *circuitBreakers) permits(provider AIProvider) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.circuits[provider]
+	if !ok {
+		return true
+	}
+	switch circuit.state {
+	case CircuitOpen:
+		return !b.now().Before(circuit.openedAt.Add(circuit.cooldown))
+	case CircuitHalfOpen:
+		return !circuit.probing
+	}
+	return true
+}
+
+// acquire admits a call to provider. Once an open circuit's cooldown elapses
+// the first caller becomes the half-open probe; others are rejected until the
+// probe reports back.
+func (b *circuitBreakers) acquire(provider AIProvider) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	circuit := b.circuitLocked(provider)
+	var events []ProviderCircuitEvent
+	admitted := true
+	switch circuit.state {
+	case CircuitOpen:
+		if b.now().Before(circuit.openedAt.Add(circuit.cooldown)) {
+			admitted = false
+			break
+		}
+		circuit.state = CircuitHalfOpen
+		circuit.probing = true
+		events = append(events, b.eventLocked(provider, CircuitOpen, CircuitHalfOpen, "cooldown_elapsed", circuit))
+	case CircuitHalfOpen:
+		if circuit.probing {
+			admitted = false
+			break
+		}
+		circuit.probing = true
+	}
+	b.mu.Unlock()
+	b.emit(events)
+	return admitted
+}
+
+// abandon releases a claimed probe slot for a call whose caller gave up, so
+// the outcome says nothing about the provider.
+func (b *circuitBreakers) abandon(provider AIProvider) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if circuit, ok := b.circuits[provider]; ok && circuit.state == CircuitHalfOpen {
+		circuit.probing = false
+	}
+}
+
+// record feeds a finished call into provider's breaker.
+func (b *circuitBreakers) record(provider AIProvider, latency time.Duration, err error) {
+	if b == nil {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		b.abandon(provider)
+		return
+	}
+	call := circuitCall{
+		failed:  err != nil,
+		slow:    b.cfg.SlowCall > 0 && latency >= b.cfg.SlowCall,
+		latency: latency,
+		class:   RetryReasonCode(err),
+	}
+
+	b.mu.Lock()
+	circuit := b.circuitLocked(provider)
+	now := b.now()
+	if call.failed {
+		circuit.lastError = call.class
+		circuit.lastErrorAt = now
+	}
+	var events []ProviderCircuitEvent
+	switch circuit.state {
+	case CircuitHalfOpen:
+		circuit.probing = false
+		if call.failed || call.slow {
+			circuit.cooldown = minDuration(circuit.cooldown*2, b.cfg.MaxCooldown)
+			b.openLocked(circuit, now, "probe_failed")
+			events = append(events, b.eventLocked(provider, CircuitHalfOpen, CircuitOpen, "probe_failed", circuit))
+		} else {
+			circuit.state = CircuitClosed
+			circuit.calls = circuit.calls[:0]
+			circuit.next = 0
+			circuit.cooldown = b.cfg.Cooldown
+			events = append(events, b.eventLocked(provider, CircuitHalfOpen, CircuitClosed, "probe_succeeded", circuit))
+		}
+	case CircuitClosed:
+		b.appendCallLocked(circuit, call)
+		if reason := b.tripReasonLocked(circuit); reason != "" {
+			b.openLocked(circuit, now, reason)
+			events = append(events, b.eventLocked(provider, CircuitClosed, CircuitOpen, reason, circuit))
+		}
+	}
+	b.mu.Unlock()
+	b.emit(events)
+}
+
+func (b *circuitBreakers) appendCallLocked(circuit *providerCircuit, call circuitCall) {
+	window := b.cfg.Window
+	if window <= 0 {
+		window = 1
+	}
+	if len(circuit.calls) < window {
+		circuit.calls = append(circuit.calls, call)
+		return
+	}
+	circuit.calls[circuit.next] = call
+	circuit.next = (circuit.next + 1) % window
+}
+
+func (b *circuitBreakers) tripReasonLocked(circuit *providerCircuit) string {
+	if len(circuit.calls) < b.cfg.MinCalls {
+		return ""
+	}
+	errorRate, slowRate, _ := circuitRates(circuit.calls)
+	if b.cfg.ErrorRate > 0 && errorRate >= b.cfg.ErrorRate {
+		return "error_rate"
+	}
+	if b.cfg.SlowCallRate > 0 && slowRate >= b.cfg.SlowCallRate {
+		return "slow_calls"
+	}
+	return ""
+}
+
+func (b *circuitBreakers) openLocked(circuit *providerCircuit, now time.Time, reason string) {
+	circuit.state = CircuitOpen
+	circuit.openedAt = now
+	circuit.trips++
+	circuit.tripReason = reason
+}
+
+func (b *circuitBreakers) eventLocked(provider AIProvider, from, to CircuitState, reason string, circuit *providerCircuit) ProviderCircuitEvent {
+	event := ProviderCircuitEvent{
+		Provider:   provider,
+		From:       from,
+		To:         to,
+		Reason:     reason,
+		ErrorClass: circuit.lastError,
+		At:         b.now(),
+	}
+	if to == CircuitOpen {
+		retryAt := circuit.openedAt.Add(circuit.cooldown)
+		event.RetryAt = &retryAt
+	}
+	return event
+}
+
+func (b *circuitBreakers) emit(events []ProviderCircuitEvent) {
+	if len(events) == 0 {
+		return
+	}
+	b.mu.Lock()
+	listeners := make([]func(ProviderCircuitEvent), len(b.listeners))
+	copy(listeners, b.listeners)
+	b.mu.Unlock()
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+func (b *circuitBreakers) subscribe(listener func(ProviderCircuitEvent)) {
+	if b == nil || listener == nil {
+		return
+	}
+	b.mu.Lock()
+	b.listeners = append(b.listeners, listener)
+	b.mu.Unlock()
+}
+
+func (b *circuitBreakers) status(provider AIProvider) *ProviderCircuitStatus {
+	status := &ProviderCircuitStatus{State: CircuitClosed}
+	if b == nil {
+		return status
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.circuits[provider]
+	if !ok {
+		return status
+	}
+	status.State = circuit.state
+	status.Calls = len(circuit.calls)
+	status.Trips = circuit.trips
+	status.TripReason = circuit.tripReason
+	status.LastError = circuit.lastError
+	if !circuit.lastErrorAt.IsZero() {
+		lastErrorAt := circuit.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	var avgLatency time.Duration
+	status.ErrorRate, status.SlowCallRate, avgLatency = circuitRates(circuit.calls)
+	status.AvgLatencyMs = avgLatency.Milliseconds()
+	for _, call := range circuit.calls {
+		if !call.failed {
+			continue
+		}
+		if status.RecentErrors == nil {
+			status.RecentErrors = make(map[string]int)
+		}
+		status.RecentErrors[call.class]++
+	}
+	if circuit.state != CircuitClosed {
+		openedAt := circuit.openedAt
+		retryAt := openedAt.Add(circuit.cooldown)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+func circuitRates(calls []circuitCall) (errorRate, slowRate float64, avgLatency time.Duration) {
+	if len(calls) == 0 {
+		return 0, 0, 0
+	}
+	var failed, slow int
+	var total time.Duration
+	for _, call := range calls {
+		if call.failed {
+			failed++
+		}
+		if call.slow {
+			slow++
+		}
+		total += call.latency
+	}
+	n := float64(len(calls))
+	return float64(failed) / n, float64(slow) / n, total / time.Duration(len(calls))
+}
+
+func circuitOpenError(provider AIProvider) error {
+	return fmt.Errorf("%w: provider %s is paused after repeated failures", errCircuitOpen, provider)
+}
+
+// GetCircuitStatus returns the circuit breaker state of every configured
+// provider, keyed by provider name.
+func (r *AIRouter) GetCircuitStatus() map[string]*ProviderCircuitStatus {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	providers := make([]AIProvider, 0, len(r.clients))
+	for provider := range r.clients {
+		providers = append(providers, provider)
+	}
+	r.mu.RUnlock()
+
+	result := make(map[string]*ProviderCircuitStatus, len(providers))
+	for _, provider := range providers {
+		result[string(provider)] = r.breakers.status(provider)
+	}
+	return result
+}
+
+// OnCircuitStateChange registers a listener for provider circuit breaker
+// transitions. Listeners run synchronously on the calling request's goroutine
+// and must not block.
+func (r *AIRouter) OnCircuitStateChange(listener func(ProviderCircuitEvent)) {
+	if r == nil {
+		return
+	}
+	r.breakers.subscribe(listener)
+}
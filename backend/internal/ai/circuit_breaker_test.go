@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTripsOnErrorRateAndProbesHalfOpen(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	breakers := newCircuitBreakers(CircuitBreakerConfig{
+		Window: 10, MinCalls: 4, ErrorRate: 0.5, Cooldown: 30 * time.Second, MaxCooldown: time.Minute,
+	})
+	breakers.now = func() time.Time { return now }
+	var events []ProviderCircuitEvent
+	breakers.subscribe(func(event ProviderCircuitEvent) { events = append(events, event) })
+
+	breakers.record(ProviderClaude, time.Second, nil)
+	breakers.record(ProviderClaude, time.Second, errors.New("service_error: upstream 502"))
+	breakers.record(ProviderClaude, time.Second, context.Canceled)
+	breakers.record(ProviderClaude, time.Second, errors.New("rate_limit: slow down"))
+	if len(events) != 0 {
+		t.Fatalf("circuit tripped before MinCalls: %+v", events)
+	}
+	breakers.record(ProviderClaude, time.Second, nil)
+	if len(events) != 1 || events[0].To != CircuitOpen || events[0].Reason != "error_rate" || events[0].ErrorClass != "rate_limited" {
+		t.Fatalf("expected error_rate trip, got %+v", events)
+	}
+	if breakers.permits(ProviderClaude) || breakers.acquire(ProviderClaude) {
+		t.Fatal("open circuit should reject calls during cooldown")
+	}
+	status := breakers.status(ProviderClaude)
+	if status.State != CircuitOpen || status.Calls != 4 || status.RecentErrors["provider_error"] != 1 || status.RecentErrors["rate_limited"] != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	now = now.Add(30 * time.Second)
+	if !breakers.acquire(ProviderClaude) || breakers.acquire(ProviderClaude) {
+		t.Fatal("half-open circuit should admit exactly one probe")
+	}
+	breakers.record(ProviderClaude, time.Second, errors.New("timeout waiting for response"))
+	if got := events[len(events)-1]; got.To != CircuitOpen || got.Reason != "probe_failed" || !got.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("failed probe should reopen with a doubled cooldown, got %+v", got)
+	}
+
+	now = now.Add(time.Minute)
+	if !breakers.acquire(ProviderClaude) {
+		t.Fatal("probe should be admitted after the doubled cooldown")
+	}
+	breakers.record(ProviderClaude, time.Second, nil)
+	if got := events[len(events)-1]; got.To != CircuitClosed || got.Reason != "probe_succeeded" {
+		t.Fatalf("successful probe should close the circuit, got %+v", got)
+	}
+	if status := breakers.status(ProviderClaude); status.State != CircuitClosed || status.Calls != 0 || status.Trips != 2 {
+		t.Fatalf("unexpected status after recovery: %+v", status)
+	}
+}
+
+func TestGenerateRoutesAroundOpenCircuit(t *testing.T) {
+	primaryCalls := 0
+	router := &AIRouter{
+		clients: map[AIProvider]AIClient{
+			ProviderGPT4: &routerStubClient{
+				generate: func(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+					primaryCalls++
+					return nil, errors.New("service_error: upstream 500")
+				},
+			},
+			ProviderClaude: &routerStubClient{},
+		},
+		config:       DefaultRouterConfig(),
+		healthStatus: map[AIProvider]string{ProviderGPT4: "ok", ProviderClaude: "ok"},
+		healthCheck:  map[AIProvider]bool{ProviderGPT4: true, ProviderClaude: true},
+		breakers: newCircuitBreakers(CircuitBreakerConfig{
+			Window: 4, MinCalls: 2, ErrorRate: 0.5, Cooldown: time.Minute, MaxCooldown: time.Minute,
+		}),
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := router.Generate(context.Background(), &AIRequest{
+			Provider: ProviderGPT4, Capability: CapabilityCodeGeneration, Prompt: "Build a dashboard",
+		})
+		if err != nil || resp == nil || resp.Provider != ProviderClaude {
+			t.Fatalf("request %d should fall back to claude, got %+v, %v", i, resp, err)
+		}
+	}
+	if primaryCalls != 2 {
+		t.Fatalf("primary calls = %d, want 2 before the circuit opened", primaryCalls)
+	}
+	if status := router.GetCircuitStatus()[string(ProviderGPT4)]; status.State != CircuitOpen || status.RecentErrors["provider_error"] != 2 {
+		t.Fatalf("unexpected gpt4 circuit status: %+v", status)
+	}
+
+	_, err := router.Generate(context.Background(), &AIRequest{
+		Provider: ProviderGPT4, Capability: CapabilityCodeGeneration, Prompt: "Build a dashboard", DisableFallback: true,
+	})
+	if err == nil || primaryCalls != 2 {
+		t.Fatalf("pinned request should fail fast on an open circuit, err=%v calls=%d", err, primaryCalls)
+	}
+}
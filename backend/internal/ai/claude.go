@@ -30,6 +30,8 @@ type claudeRequest struct {
 	Temperature *float32        `json:"temperature,omitempty"`
 	// System is either a plain string or []claudeSystemContent (for cache_control support).
 	System interface{} `json:"system,omitempty"`
+	// StopSequences must contain non-whitespace; the API rejects "\n\n".
+	StopSequences []string `json:"stop_sequences,omitempty"`
 }
 
 type claudeMessage struct {
@@ -79,7 +81,8 @@ type claudeResponse struct {
 		Type string `json:"type"`
 		Text string `json:"text"`
 	} `json:"content"`
-	Usage struct {
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
 		InputTokens              int `json:"input_tokens"`
 		OutputTokens             int `json:"output_tokens"`
 		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
@@ -148,8 +151,9 @@ func (c *ClaudeClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 				Content: userPrompt,
 			},
 		},
-		Temperature: claudeTemperaturePtr(model, req.Temperature),
-		System:      system,
+		Temperature:   claudeTemperaturePtr(model, req.Temperature),
+		System:        system,
+		StopSequences: stopSequences(req, 4, false),
 	}
 
 	// Make API request
@@ -185,6 +189,7 @@ func (c *ClaudeClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 			"model":                       model,
 			"cache_creation_input_tokens": resp.Usage.CacheCreationInputTokens,
 			"cache_read_input_tokens":     resp.Usage.CacheReadInputTokens,
+			"finish_reason":               resp.StopReason,
 		},
 		Usage: &Usage{
 			PromptTokens:     resp.Usage.InputTokens,
@@ -363,7 +368,7 @@ func (c *ClaudeClient) makeRequest(ctx context.Context, req any) (*claudeRespons
 		// Parse specific error types for better error messages
 		switch resp.StatusCode {
 		case 429:
-			return nil, fmt.Errorf("RATE_LIMIT: Claude API rate limit exceeded. Please wait before retrying")
+			return nil, withRetryAfter(fmt.Errorf("RATE_LIMIT: Claude API rate limit exceeded. Please wait before retrying"), resp.Header)
 		case 403:
 			return nil, fmt.Errorf("FORBIDDEN: Claude API access denied - check API key permissions")
 		case 401:
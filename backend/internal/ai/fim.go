@@ -0,0 +1,62 @@
+package ai
+
+import "strings"
+
+// FIMInput is the code around the cursor for a fill-in-the-middle request.
+// Prefix already carries any context the caller wants the model to see.
+type FIMInput struct {
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+}
+
+// fimModelMarkers are substrings of model names trained with FIM tokens.
+// Chat-tuned models answer the prompt instead.
+var fimModelMarkers = []string{
+	"codestral",
+	"codellama",
+	"code-llama",
+	"starcoder",
+	"deepseek-coder",
+	"qwen2.5-coder",
+	"qwen3-coder",
+	"codegemma",
+	"granite-code",
+	"stable-code",
+}
+
+// SupportsFIM reports whether model accepts a prefix/suffix completion.
+func SupportsFIM(model string) bool {
+	m := strings.ToLower(model)
+	// Instruction-tuned variants drop the FIM objective.
+	if strings.Contains(m, "instruct") {
+		return false
+	}
+	for _, marker := range fimModelMarkers {
+		if strings.Contains(m, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// stopSequences returns req.Stop trimmed to a provider's limit. Providers
+// that reject whitespace-only sequences pass allowWhitespace=false.
+func stopSequences(req *AIRequest, limit int, allowWhitespace bool) []string {
+	if req == nil || len(req.Stop) == 0 {
+		return nil
+	}
+	out := make([]string, 0, limit)
+	for _, stop := range req.Stop {
+		if len(out) == limit {
+			break
+		}
+		if stop == "" || (!allowWhitespace && strings.TrimSpace(stop) == "") {
+			continue
+		}
+		out = append(out, stop)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -49,6 +49,7 @@ type geminiGenConfig struct {
 	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
 	TopP            float32               `json:"topP,omitempty"`
 	TopK            int                   `json:"topK,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
 	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
 }
 
@@ -126,6 +127,7 @@ func (g *GeminiClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		MaxOutputTokens: maxOut,
 		TopP:            0.8,
 		TopK:            40,
+		StopSequences:   stopSequences(req, 5, true),
 	}
 	if budget := geminiThinkingBudgetForModel(model, req.PowerMode, len(systemPrompt)+len(userPrompt)); budget > 0 {
 		genConfig.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: budget}
@@ -172,8 +174,12 @@ func (g *GeminiClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 
 	// Extract response content
 	content := ""
-	if len(resp.Candidates) > 0 && len(resp.Candidates[0].Content.Parts) > 0 {
-		content = resp.Candidates[0].Content.Parts[0].Text
+	finishReason := ""
+	if len(resp.Candidates) > 0 {
+		finishReason = resp.Candidates[0].FinishReason
+		if len(resp.Candidates[0].Content.Parts) > 0 {
+			content = resp.Candidates[0].Content.Parts[0].Text
+		}
 	}
 
 	return &AIResponse{
@@ -181,7 +187,8 @@ func (g *GeminiClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		Provider: ProviderGemini,
 		Content:  content,
 		Metadata: map[string]interface{}{
-			"model": resolvedModel,
+			"model":         resolvedModel,
+			"finish_reason": finishReason,
 		},
 		Usage: &Usage{
 			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
@@ -422,7 +429,7 @@ func (g *GeminiClient) makeRequest(ctx context.Context, url string, req *geminiR
 		detail := sanitizeProviderBody(body, g.apiKey)
 		switch resp.StatusCode {
 		case 429:
-			return nil, fmt.Errorf("RATE_LIMIT: Gemini API rate limit exceeded - please wait before retrying%s", detailSuffix(resp.StatusCode, detail))
+			return nil, withRetryAfter(fmt.Errorf("RATE_LIMIT: Gemini API rate limit exceeded - please wait before retrying%s", detailSuffix(resp.StatusCode, detail)), resp.Header)
 		case 403:
 			// Check if it's a quota issue
 			if bytes.Contains(body, []byte("quota")) || bytes.Contains(body, []byte("QUOTA")) {
@@ -28,6 +28,7 @@ type grokRequest struct {
 	Messages    []grokMessage `json:"messages"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Temperature float32       `json:"temperature,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
 	Stream      bool          `json:"stream"`
 }
 
@@ -90,6 +91,7 @@ func (g *GrokClient) Generate(ctx context.Context, req *AIRequest) (*AIResponse,
 		Messages:    messages,
 		MaxTokens:   g.getMaxTokens(req),
 		Temperature: req.Temperature,
+		Stop:        stopSequences(req, 4, true),
 		Stream:      false,
 	}
 
@@ -109,8 +111,10 @@ func (g *GrokClient) Generate(ctx context.Context, req *AIRequest) (*AIResponse,
 	g.updateUsage(resp.Usage.TotalTokens, cost, time.Since(startTime))
 
 	content := ""
+	finishReason := ""
 	if len(resp.Choices) > 0 {
 		content = resp.Choices[0].Message.Content
+		finishReason = resp.Choices[0].FinishReason
 	}
 
 	return &AIResponse{
@@ -118,7 +122,8 @@ func (g *GrokClient) Generate(ctx context.Context, req *AIRequest) (*AIResponse,
 		Provider: ProviderGrok,
 		Content:  content,
 		Metadata: map[string]interface{}{
-			"model": resp.Model,
+			"model":         resp.Model,
+			"finish_reason": finishReason,
 		},
 		Usage: &Usage{
 			PromptTokens:     resp.Usage.PromptTokens,
@@ -252,7 +257,7 @@ func (g *GrokClient) makeRequest(ctx context.Context, req *grokRequest) (*grokRe
 		detail := sanitizeProviderBody(body, g.apiKey)
 		switch resp.StatusCode {
 		case 429:
-			return nil, fmt.Errorf("RATE_LIMIT: Grok API rate limit exceeded%s", detailSuffix(resp.StatusCode, detail))
+			return nil, withRetryAfter(fmt.Errorf("RATE_LIMIT: Grok API rate limit exceeded%s", detailSuffix(resp.StatusCode, detail)), resp.Header)
 		case 403:
 			if grokErrorBodyIndicatesDisabledKey(body) {
 				// Intentionally omit the body here: it contains the (now-redacted) key and the
@@ -54,3 +54,19 @@ func ParseProvider(value string) AIProvider {
 		return ""
 	}
 }
+
+// HitOutputLimit reports whether the provider stopped because the response
+// reached its output token limit, per Metadata["finish_reason"]: "length"
+// (OpenAI-compatible), "max_tokens" (Claude), "MAX_TOKENS" (Gemini) or
+// "max_output_tokens" (OpenAI Responses).
+func HitOutputLimit(resp *AIResponse) bool {
+	if resp == nil || resp.Metadata == nil {
+		return false
+	}
+	reason, _ := resp.Metadata["finish_reason"].(string)
+	switch strings.ToLower(strings.TrimSpace(reason)) {
+	case "length", "max_tokens", "max_output_tokens":
+		return true
+	}
+	return false
+}
@@ -35,3 +35,23 @@ func TestActualProviderRejectsUnknownMetadataProvider(t *testing.T) {
 		t.Fatalf("ActualProvider() = %q, want %q", got, ProviderGemini)
 	}
 }
+
+func TestHitOutputLimitNormalizesProviderFinishReasons(t *testing.T) {
+	for reason, want := range map[string]bool{
+		"length":            true,
+		"max_tokens":        true,
+		"MAX_TOKENS":        true,
+		"max_output_tokens": true,
+		"stop":              false,
+		"end_turn":          false,
+		"":                  false,
+	} {
+		resp := &AIResponse{Metadata: map[string]interface{}{"finish_reason": reason}}
+		if got := HitOutputLimit(resp); got != want {
+			t.Errorf("HitOutputLimit(%q) = %v, want %v", reason, got, want)
+		}
+	}
+	if HitOutputLimit(&AIResponse{}) {
+		t.Error("responses without a finish reason are not truncated")
+	}
+}
@@ -67,6 +67,7 @@ type ollamaRequest struct {
 	Stream          bool            `json:"stream"`
 	ReasoningEffort string          `json:"reasoning_effort,omitempty"`
 	Think           *bool           `json:"think,omitempty"`
+	Stop            []string        `json:"stop,omitempty"`
 	UseContextCache bool            `json:"use_context_cache,omitempty"` // Moonshot direct API only
 }
 
@@ -105,6 +106,34 @@ type ollamaResponse struct {
 	} `json:"error,omitempty"`
 }
 
+// ollamaFIMRequest is the OpenAI-compatible /v1/completions shape; Ollama
+// applies the model's own FIM template when Suffix is set.
+type ollamaFIMRequest struct {
+	Model       string   `json:"model"`
+	Prompt      string   `json:"prompt"`
+	Suffix      string   `json:"suffix"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature float32  `json:"temperature,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Stream      bool     `json:"stream"`
+}
+
+type ollamaFIMResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
 // ollamaModelsResponse represents the response from /api/tags
 type ollamaModelsResponse struct {
 	Models []struct {
@@ -203,8 +232,12 @@ func (o *OllamaClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 
 	startTime := time.Now()
 
-	messages := o.buildMessages(req)
 	model := o.getModel(req)
+	if req.FIM != nil && SupportsFIM(model) {
+		return o.generateFIM(ctx, req, model, startTime)
+	}
+
+	messages := o.buildMessages(req)
 	reasoningEffort := o.reasoningEffort(req, model)
 	reasoningBudget := o.reasoningTokenBudget(req, reasoningEffort)
 
@@ -216,6 +249,7 @@ func (o *OllamaClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		Stream:          false,
 		ReasoningEffort: reasoningEffort,
 		Think:           o.thinkEnabled(req, reasoningEffort),
+		Stop:            stopSequences(req, 4, true),
 		UseContextCache: req.CacheSystemPrompt && o.isMoonshotAPI(),
 	}
 
@@ -293,6 +327,58 @@ func (o *OllamaClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 	}, nil
 }
 
+// generateFIM completes between req.FIM.Prefix and req.FIM.Suffix with a
+// FIM-trained model. The content is the raw middle, with no prose to strip.
+func (o *OllamaClient) generateFIM(ctx context.Context, req *AIRequest, model string, startTime time.Time) (*AIResponse, error) {
+	resp, err := o.makeFIMRequest(ctx, &ollamaFIMRequest{
+		Model:       model,
+		Prompt:      req.FIM.Prefix,
+		Suffix:      req.FIM.Suffix,
+		MaxTokens:   o.getMaxTokens(req, 0),
+		Temperature: req.Temperature,
+		Stop:        stopSequences(req, 4, true),
+		Stream:      false,
+	})
+	if err != nil {
+		o.incrementErrorCount()
+		return &AIResponse{
+			ID:        req.ID,
+			Provider:  ProviderOllama,
+			Error:     err.Error(),
+			Duration:  time.Since(startTime),
+			CreatedAt: time.Now(),
+		}, err
+	}
+
+	cost := o.calculateCost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, 0, model)
+	o.updateUsage(resp.Usage.TotalTokens, cost, time.Since(startTime))
+
+	content, finishReason := "", ""
+	if len(resp.Choices) > 0 {
+		content = resp.Choices[0].Text
+		finishReason = resp.Choices[0].FinishReason
+	}
+
+	return &AIResponse{
+		ID:       req.ID,
+		Provider: ProviderOllama,
+		Content:  content,
+		Metadata: map[string]interface{}{
+			"model":         model,
+			"finish_reason": finishReason,
+			"fim":           true,
+		},
+		Usage: &Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+			Cost:             cost,
+		},
+		Duration:  time.Since(startTime),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
 func ollamaReasoningBudgetError(finishReason string, reasoningChars, completionTokens int) error {
 	return fmt.Errorf("OLLAMA_REASONING_BUDGET_EXHAUSTED: model returned reasoning but no visible content; output was truncated before final answer (finish_reason=%s, reasoning_chars=%d, completion_tokens=%d). Increase max_tokens/reasoning budget or lower reasoning_effort", finishReason, reasoningChars, completionTokens)
 }
@@ -638,12 +724,50 @@ func (o *OllamaClient) normalizeModelAlias(model string) string {
 
 // makeRequest sends HTTP request to Ollama API
 func (o *OllamaClient) makeRequest(ctx context.Context, req *ollamaRequest) (*ollamaResponse, error) {
-	jsonData, err := json.Marshal(req)
+	body, err := o.post(ctx, "/v1/chat/completions", req.Model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var ollamaResp ollamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if ollamaResp.Error != nil {
+		return nil, fmt.Errorf("Ollama API error: %s", ollamaResp.Error.Message)
+	}
+
+	return &ollamaResp, nil
+}
+
+// makeFIMRequest calls the OpenAI-compatible completions endpoint, which
+// accepts a suffix for fill-in-the-middle models.
+func (o *OllamaClient) makeFIMRequest(ctx context.Context, req *ollamaFIMRequest) (*ollamaFIMResponse, error) {
+	body, err := o.post(ctx, "/v1/completions", req.Model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fimResp ollamaFIMResponse
+	if err := json.Unmarshal(body, &fimResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if fimResp.Error != nil {
+		return nil, fmt.Errorf("Ollama API error: %s", fimResp.Error.Message)
+	}
+
+	return &fimResp, nil
+}
+
+func (o *OllamaClient) post(ctx context.Context, path, model string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	url := o.baseURL + "/v1/chat/completions"
+	url := o.baseURL + path
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -672,9 +796,9 @@ func (o *OllamaClient) makeRequest(ctx context.Context, req *ollamaRequest) (*ol
 		case 401, 403:
 			return nil, fmt.Errorf("AUTH_ERROR: Ollama request failed with status %d: %s", resp.StatusCode, string(body))
 		case 429:
-			return nil, fmt.Errorf("RATE_LIMITED: Ollama request failed with status %d: %s", resp.StatusCode, string(body))
+			return nil, withRetryAfter(fmt.Errorf("RATE_LIMITED: Ollama request failed with status %d: %s", resp.StatusCode, string(body)), resp.Header)
 		case 404:
-			return nil, fmt.Errorf("MODEL_NOT_FOUND: Model '%s' not installed. Run: ollama pull %s", req.Model, req.Model)
+			return nil, fmt.Errorf("MODEL_NOT_FOUND: Model '%s' not installed. Run: ollama pull %s", model, model)
 		case 500, 502, 503, 504:
 			return nil, fmt.Errorf("SERVICE_ERROR: Ollama server error (status %d). Is Ollama running?", resp.StatusCode)
 		default:
@@ -682,16 +806,7 @@ func (o *OllamaClient) makeRequest(ctx context.Context, req *ollamaRequest) (*ol
 		}
 	}
 
-	var ollamaResp ollamaResponse
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	if ollamaResp.Error != nil {
-		return nil, fmt.Errorf("Ollama API error: %s", ollamaResp.Error.Message)
-	}
-
-	return &ollamaResp, nil
+	return body, nil
 }
 
 // GetCapabilities returns capabilities Ollama supports
@@ -2,6 +2,7 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -321,3 +322,66 @@ func TestNewOllamaClientLeavesConcurrencyUnbounded(t *testing.T) {
 		t.Fatal("expected NewOllamaClient (local) to leave concurrency semaphore nil")
 	}
 }
+
+func TestOllamaUsesCompletionsEndpointForFIMModels(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"qwen2.5-coder:7b","choices":[{"text":" a + b","finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`))
+	}))
+	defer srv.Close()
+
+	client := NewOllamaClient(srv.URL, "")
+	resp, err := client.Generate(context.Background(), &AIRequest{
+		Capability: CapabilityCodeCompletion,
+		Model:      "qwen2.5-coder:7b",
+		Prompt:     "ignored for FIM",
+		Stop:       []string{"\n", "\nfunc ", "", "\ntype ", "\nvar ", "```"},
+		FIM:        &FIMInput{Prefix: "func add(a, b int) int {\n\treturn", Suffix: "\n}\n"},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if gotPath != "/v1/completions" {
+		t.Fatalf("path = %q, want /v1/completions", gotPath)
+	}
+	if gotBody["suffix"] != "\n}\n" || !strings.HasSuffix(gotBody["prompt"].(string), "\treturn") {
+		t.Fatalf("FIM body = %v", gotBody)
+	}
+	if stops := gotBody["stop"].([]interface{}); len(stops) != 4 {
+		t.Fatalf("stop = %v, want the first 4 non-empty sequences", stops)
+	}
+	if resp.Content != " a + b" || resp.Metadata["fim"] != true {
+		t.Fatalf("response = %q %v", resp.Content, resp.Metadata)
+	}
+
+	// Chat models answer the prompt even when FIM input is present.
+	resp, err = client.Generate(context.Background(), &AIRequest{
+		Capability: CapabilityCodeCompletion,
+		Model:      "llama3.1:8b",
+		Prompt:     "complete",
+		FIM:        &FIMInput{Prefix: "x", Suffix: "y"},
+	})
+	if gotPath != "/v1/chat/completions" {
+		t.Fatalf("chat model path = %q, want /v1/chat/completions (err %v, resp %v)", gotPath, err, resp)
+	}
+}
+
+func TestSupportsFIM(t *testing.T) {
+	cases := map[string]bool{
+		"qwen2.5-coder:7b":          true,
+		"deepseek-coder:6.7b-base":  true,
+		"codestral-latest":          true,
+		"qwen2.5-coder:7b-instruct": false,
+		"deepseek-r1:14b":           false,
+		"gpt-4o-mini":               false,
+	}
+	for model, want := range cases {
+		if got := SupportsFIM(model); got != want {
+			t.Errorf("SupportsFIM(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
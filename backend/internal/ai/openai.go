@@ -29,6 +29,7 @@ type openAIRequest struct {
 	MaxTokens           int             `json:"max_tokens,omitempty"`
 	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
 	Temperature         float32         `json:"temperature,omitempty"`
+	Stop                []string        `json:"stop,omitempty"`
 	Stream              bool            `json:"stream"`
 }
 
@@ -99,8 +100,11 @@ type openAIResponsesAPIResponse struct {
 			Text string `json:"text"`
 		} `json:"content"`
 	} `json:"output"`
-	OutputText string `json:"output_text,omitempty"`
-	Usage      struct {
+	OutputText        string `json:"output_text,omitempty"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details,omitempty"`
+	Usage struct {
 		InputTokens  int `json:"input_tokens"`
 		OutputTokens int `json:"output_tokens"`
 		TotalTokens  int `json:"total_tokens"`
@@ -145,6 +149,7 @@ func (o *OpenAIClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		Model:       model,
 		Messages:    messages,
 		Temperature: req.Temperature,
+		Stop:        stopSequences(req, 4, true),
 		Stream:      false,
 	}
 	if useMaxCompletionTokens(model) {
@@ -159,6 +164,7 @@ func (o *OpenAIClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		outputTokens   int
 		totalTokens    int
 		effectiveModel string
+		finishReason   string
 		err            error
 	)
 
@@ -171,6 +177,9 @@ func (o *OpenAIClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 			outputTokens = responsesResp.Usage.OutputTokens
 			totalTokens = responsesResp.Usage.TotalTokens
 			effectiveModel = responsesResp.Model
+			if responsesResp.IncompleteDetails != nil {
+				finishReason = responsesResp.IncompleteDetails.Reason
+			}
 		} else {
 			// When the caller explicitly selected a GPT-5 tier, preserve that contract
 			// and let the outer router choose a different provider rather than silently
@@ -182,6 +191,7 @@ func (o *OpenAIClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 				if err == nil {
 					if len(chatResp.Choices) > 0 {
 						content = chatResp.Choices[0].Message.Content
+						finishReason = chatResp.Choices[0].FinishReason
 					}
 					inputTokens = chatResp.Usage.PromptTokens
 					outputTokens = chatResp.Usage.CompletionTokens
@@ -196,6 +206,7 @@ func (o *OpenAIClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		if err == nil {
 			if len(chatResp.Choices) > 0 {
 				content = chatResp.Choices[0].Message.Content
+				finishReason = chatResp.Choices[0].FinishReason
 			}
 			inputTokens = chatResp.Usage.PromptTokens
 			outputTokens = chatResp.Usage.CompletionTokens
@@ -229,7 +240,8 @@ func (o *OpenAIClient) Generate(ctx context.Context, req *AIRequest) (*AIRespons
 		Provider: ProviderGPT4,
 		Content:  content,
 		Metadata: map[string]interface{}{
-			"model": effectiveModel,
+			"model":         effectiveModel,
+			"finish_reason": finishReason,
 		},
 		Usage: &Usage{
 			PromptTokens:     inputTokens,
@@ -376,7 +388,7 @@ func (o *OpenAIClient) makeRequest(ctx context.Context, req *openAIRequest) (*op
 		// Parse specific error types for better error messages
 		switch resp.StatusCode {
 		case 429:
-			return nil, fmt.Errorf("RATE_LIMIT: OpenAI API rate limit exceeded. Please wait before retrying")
+			return nil, withRetryAfter(fmt.Errorf("RATE_LIMIT: OpenAI API rate limit exceeded. Please wait before retrying"), resp.Header)
 		case 403:
 			return nil, fmt.Errorf("FORBIDDEN: OpenAI API access denied - check API key permissions")
 		case 401:
@@ -456,7 +468,7 @@ func (o *OpenAIClient) makeResponsesRequest(
 	if resp.StatusCode != http.StatusOK {
 		switch resp.StatusCode {
 		case 429:
-			return nil, fmt.Errorf("RATE_LIMIT: OpenAI API rate limit exceeded. Please wait before retrying")
+			return nil, withRetryAfter(fmt.Errorf("RATE_LIMIT: OpenAI API rate limit exceeded. Please wait before retrying"), resp.Header)
 		case 403:
 			return nil, fmt.Errorf("FORBIDDEN: OpenAI API access denied - check API key permissions")
 		case 401:
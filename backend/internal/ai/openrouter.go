@@ -38,6 +38,7 @@ type openRouterRequest struct {
 	Messages    []openRouterMessage `json:"messages"`
 	MaxTokens   int                 `json:"max_tokens,omitempty"`
 	Temperature float32             `json:"temperature,omitempty"`
+	Stop        []string            `json:"stop,omitempty"`
 	Stream      bool                `json:"stream"`
 }
 
@@ -106,6 +107,7 @@ func (c *OpenRouterClient) Generate(ctx context.Context, req *AIRequest) (*AIRes
 		Messages:    messages,
 		MaxTokens:   req.MaxTokens,
 		Temperature: req.Temperature,
+		Stop:        stopSequences(req, 4, true),
 		Stream:      false,
 	}
 
@@ -133,6 +135,9 @@ func (c *OpenRouterClient) Generate(ctx context.Context, req *AIRequest) (*AIRes
 	if err != nil {
 		return nil, fmt.Errorf("openrouter: read response: %w", err)
 	}
+	if resp.StatusCode == 429 {
+		return nil, withRetryAfter(fmt.Errorf("rate_limit: openrouter HTTP 429: %s", redactSecrets(string(respBody), "")), resp.Header)
+	}
 
 	var orResp openRouterResponse
 	if err := json.Unmarshal(respBody, &orResp); err != nil {
@@ -193,6 +198,7 @@ func (c *OpenRouterClient) Generate(ctx context.Context, req *AIRequest) (*AIRes
 			"model":           actualModel,
 			"requested_model": model,
 			"provider":        "openrouter",
+			"finish_reason":   orResp.Choices[0].FinishReason,
 		},
 		Duration:  duration,
 		CreatedAt: time.Now(),
@@ -0,0 +1,231 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Region is a data residency region an AI provider serves requests from.
+type Region string
+
+const (
+	RegionUS Region = "us"
+	RegionEU Region = "eu"
+)
+
+// KnownRegions lists the regions providers can be tagged with and
+// organizations can allow.
+var KnownRegions = []Region{RegionUS, RegionEU}
+
+// ParseRegion normalizes a region name, reporting whether it is known.
+func ParseRegion(value string) (Region, bool) {
+	region := Region(strings.ToLower(strings.TrimSpace(value)))
+	for _, known := range KnownRegions {
+		if region == known {
+			return region, true
+		}
+	}
+	return "", false
+}
+
+// defaultProviderRegions tags each hosted provider with where its public API
+// processes requests. DeepSeek and GLM are served from outside the US and EU,
+// and Ollama is wherever it is deployed, so they are untagged by default and
+// never satisfy a region policy. AI_PROVIDER_REGIONS overrides the tags.
+var defaultProviderRegions = map[AIProvider][]Region{
+	ProviderClaude:     {RegionUS},
+	ProviderGPT4:       {RegionUS},
+	ProviderGemini:     {RegionUS},
+	ProviderGrok:       {RegionUS},
+	ProviderOpenRouter: {RegionUS},
+}
+
+// ErrNoCompliantProvider is returned when no available provider serves any of
+// the regions a request is allowed to use.
+var ErrNoCompliantProvider = errors.New("no AI provider available in the allowed regions")
+
+// RegionUnavailableError explains which regions a refused request was
+// limited to.
+type RegionUnavailableError struct {
+	Allowed   []Region
+	Requested AIProvider // set when a pinned provider was refused
+}
+
+func (e *RegionUnavailableError) Error() string {
+	if e.Requested != "" {
+		return fmt.Sprintf("%s: provider %s does not serve %s", ErrNoCompliantProvider, e.Requested, regionsString(e.Allowed))
+	}
+	return fmt.Sprintf("%s (%s)", ErrNoCompliantProvider, regionsString(e.Allowed))
+}
+
+func (e *RegionUnavailableError) Unwrap() error { return ErrNoCompliantProvider }
+
+// RegionPolicy resolves the regions a user's AI requests may be served from.
+// Nil regions mean unrestricted. Implemented by
+// *enterprise.AIRegionPolicyService.
+type RegionPolicy interface {
+	AllowedRegions(ctx context.Context, userID uint) ([]Region, error)
+}
+
+// providerRegionsFromEnv returns the default tags with AI_PROVIDER_REGIONS
+// applied, e.g. "ollama:eu,gpt4:us|eu". An empty region list untags a
+// provider.
+func providerRegionsFromEnv() map[AIProvider][]Region {
+	regions := make(map[AIProvider][]Region, len(defaultProviderRegions))
+	for provider, tags := range defaultProviderRegions {
+		regions[provider] = append([]Region(nil), tags...)
+	}
+	raw := strings.TrimSpace(os.Getenv("AI_PROVIDER_REGIONS"))
+	if raw == "" {
+		return regions
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		name, list, ok := strings.Cut(entry, ":")
+		provider := AIProvider(strings.ToLower(strings.TrimSpace(name)))
+		if !ok || provider == "" {
+			log.Printf("WARNING: ignoring malformed AI_PROVIDER_REGIONS entry %q", entry)
+			continue
+		}
+		var tags []Region
+		for _, value := range strings.Split(list, "|") {
+			if strings.TrimSpace(value) == "" {
+				continue
+			}
+			region, known := ParseRegion(value)
+			if !known {
+				log.Printf("WARNING: ignoring unknown region %q for provider %s in AI_PROVIDER_REGIONS", value, provider)
+				continue
+			}
+			tags = append(tags, region)
+		}
+		regions[provider] = tags
+	}
+	return regions
+}
+
+// SetRegionPolicy makes the router resolve each request's allowed regions
+// from its user when the caller did not set AllowedRegions.
+func (r *AIRouter) SetRegionPolicy(policy RegionPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.regionPolicy = policy
+}
+
+// SetProviderRegions replaces the regions a provider is tagged with.
+func (r *AIRouter) SetProviderRegions(provider AIProvider, regions ...Region) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.regions == nil {
+		r.regions = make(map[AIProvider][]Region)
+	}
+	r.regions[provider] = append([]Region(nil), regions...)
+}
+
+// GetProviderRegions returns the region tags of the configured providers.
+func (r *AIRouter) GetProviderRegions() map[AIProvider][]Region {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[AIProvider][]Region, len(r.clients))
+	for provider := range r.clients {
+		out[provider] = append([]Region{}, r.regions[provider]...)
+	}
+	return out
+}
+
+// regionFor picks the region a provider would serve the request from. With
+// no allowed regions any provider qualifies and its first tag is reported.
+func (r *AIRouter) regionFor(provider AIProvider, allowed []Region) (Region, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tags := r.regions[provider]
+	if len(allowed) == 0 {
+		if len(tags) == 0 {
+			return "", true
+		}
+		return tags[0], true
+	}
+	for _, tag := range tags {
+		for _, region := range allowed {
+			if tag == region {
+				return tag, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (r *AIRouter) providerAllowedByRegion(req *AIRequest, provider AIProvider) bool {
+	if req == nil || len(req.AllowedRegions) == 0 {
+		return true
+	}
+	_, ok := r.regionFor(provider, req.AllowedRegions)
+	return ok
+}
+
+// applyRegionPolicy fills in the request's allowed regions from the user's
+// organization policy. Lookup failures refuse the request rather than risk
+// routing outside an allowed region.
+func (r *AIRouter) applyRegionPolicy(ctx context.Context, req *AIRequest) error {
+	r.mu.RLock()
+	policy := r.regionPolicy
+	r.mu.RUnlock()
+	if policy == nil || len(req.AllowedRegions) > 0 {
+		return nil
+	}
+	userID, err := strconv.ParseUint(strings.TrimSpace(req.UserID), 10, 64)
+	if err != nil || userID == 0 {
+		return nil
+	}
+	allowed, err := policy.AllowedRegions(ctx, uint(userID))
+	if err != nil {
+		return fmt.Errorf("resolve AI region policy: %w", err)
+	}
+	req.AllowedRegions = allowed
+	return nil
+}
+
+// tagResponseRegion records the region of the provider the router sent the
+// request to under Metadata["region"].
+func (r *AIRouter) tagResponseRegion(req *AIRequest, response *AIResponse) {
+	if response == nil {
+		return
+	}
+	provider := response.Provider
+	if provider == "" {
+		provider = req.Provider
+	}
+	region, _ := r.regionFor(provider, req.AllowedRegions)
+	if region == "" {
+		return
+	}
+	if response.Metadata == nil {
+		response.Metadata = map[string]interface{}{}
+	}
+	response.Metadata["region"] = string(region)
+}
+
+// ResponseRegion returns the region recorded on a response by the router, or
+// "" when the serving provider has no region tag.
+func ResponseRegion(response *AIResponse) string {
+	if response == nil {
+		return ""
+	}
+	region, _ := response.Metadata["region"].(string)
+	return region
+}
+
+// regionsString renders regions for logs and errors.
+func regionsString(regions []Region) string {
+	names := make([]string, 0, len(regions))
+	for _, region := range regions {
+		names = append(names, string(region))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubRegionPolicy struct {
+	regions []Region
+	err     error
+	calls   int
+}
+
+func (p *stubRegionPolicy) AllowedRegions(ctx context.Context, userID uint) ([]Region, error) {
+	p.calls++
+	return p.regions, p.err
+}
+
+func newRegionTestRouter(calls map[AIProvider]int) *AIRouter {
+	client := func(provider AIProvider) AIClient {
+		return &routerStubClient{
+			generate: func(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+				calls[provider]++
+				return &AIResponse{Provider: provider, Content: "ok"}, nil
+			},
+		}
+	}
+	return &AIRouter{
+		clients: map[AIProvider]AIClient{
+			ProviderClaude: client(ProviderClaude),
+			ProviderGPT4:   client(ProviderGPT4),
+			ProviderOllama: client(ProviderOllama),
+		},
+		config: DefaultRouterConfig(),
+		healthStatus: map[AIProvider]string{
+			ProviderClaude: "ok",
+			ProviderGPT4:   "ok",
+			ProviderOllama: "ok",
+		},
+		healthCheck: map[AIProvider]bool{
+			ProviderClaude: true,
+			ProviderGPT4:   true,
+			ProviderOllama: true,
+		},
+		regions: map[AIProvider][]Region{
+			ProviderClaude: {RegionUS},
+			ProviderGPT4:   {RegionUS},
+			ProviderOllama: {RegionEU},
+		},
+	}
+}
+
+func TestGenerateRoutesToProviderInAllowedRegion(t *testing.T) {
+	t.Parallel()
+
+	calls := map[AIProvider]int{}
+	router := newRegionTestRouter(calls)
+	policy := &stubRegionPolicy{regions: []Region{RegionEU}}
+	router.SetRegionPolicy(policy)
+
+	resp, err := router.Generate(context.Background(), &AIRequest{
+		ID:         "eu-only",
+		UserID:     "7",
+		Capability: CapabilityCodeGeneration,
+		Prompt:     "Build a dashboard",
+	})
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if resp.Provider != ProviderOllama {
+		t.Fatalf("provider = %s, want %s", resp.Provider, ProviderOllama)
+	}
+	if got := ResponseRegion(resp); got != "eu" {
+		t.Fatalf("response region = %q, want eu", got)
+	}
+	if calls[ProviderClaude] != 0 || calls[ProviderGPT4] != 0 {
+		t.Fatalf("US providers were called: %v", calls)
+	}
+	if policy.calls != 1 {
+		t.Fatalf("policy calls = %d, want 1", policy.calls)
+	}
+}
+
+func TestGenerateRefusesPinnedProviderOutsideAllowedRegions(t *testing.T) {
+	t.Parallel()
+
+	calls := map[AIProvider]int{}
+	router := newRegionTestRouter(calls)
+
+	_, err := router.Generate(context.Background(), &AIRequest{
+		ID:              "pinned-us",
+		Provider:        ProviderGPT4,
+		Capability:      CapabilityCodeGeneration,
+		Prompt:          "Build a dashboard",
+		DisableFallback: true,
+		AllowedRegions:  []Region{RegionEU},
+	})
+	if !errors.Is(err, ErrNoCompliantProvider) {
+		t.Fatalf("err = %v, want ErrNoCompliantProvider", err)
+	}
+	var regionErr *RegionUnavailableError
+	if !errors.As(err, &regionErr) || regionErr.Requested != ProviderGPT4 {
+		t.Fatalf("err = %#v, want RegionUnavailableError for gpt4", err)
+	}
+	if calls[ProviderGPT4] != 0 {
+		t.Fatalf("gpt4 calls = %d, want 0", calls[ProviderGPT4])
+	}
+}
+
+func TestGenerateFailsWhenNoProviderServesAllowedRegions(t *testing.T) {
+	t.Parallel()
+
+	calls := map[AIProvider]int{}
+	router := newRegionTestRouter(calls)
+	router.SetProviderRegions(ProviderOllama)
+
+	_, err := router.Generate(context.Background(), &AIRequest{
+		ID:             "no-eu",
+		Capability:     CapabilityCodeGeneration,
+		Prompt:         "Build a dashboard",
+		AllowedRegions: []Region{RegionEU},
+	})
+	if !errors.Is(err, ErrNoCompliantProvider) {
+		t.Fatalf("err = %v, want ErrNoCompliantProvider", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("providers were called: %v", calls)
+	}
+}
+
+func TestGenerateFailsClosedWhenRegionPolicyLookupFails(t *testing.T) {
+	t.Parallel()
+
+	calls := map[AIProvider]int{}
+	router := newRegionTestRouter(calls)
+	router.SetRegionPolicy(&stubRegionPolicy{err: errors.New("db down")})
+
+	_, err := router.Generate(context.Background(), &AIRequest{
+		ID:         "lookup-fails",
+		UserID:     "7",
+		Capability: CapabilityCodeGeneration,
+		Prompt:     "Build a dashboard",
+	})
+	if err == nil {
+		t.Fatal("expected region policy lookup error")
+	}
+	if len(calls) != 0 {
+		t.Fatalf("providers were called: %v", calls)
+	}
+}
+
+func TestGenerateTagsUnrestrictedResponseWithProviderRegion(t *testing.T) {
+	t.Parallel()
+
+	calls := map[AIProvider]int{}
+	router := newRegionTestRouter(calls)
+
+	resp, err := router.Generate(context.Background(), &AIRequest{
+		ID:         "unrestricted",
+		Provider:   ProviderClaude,
+		Capability: CapabilityCodeGeneration,
+		Prompt:     "Build a dashboard",
+	})
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if got := ResponseRegion(resp); got != "us" {
+		t.Fatalf("response region = %q, want us", got)
+	}
+}
+
+func TestProviderRegionsFromEnv(t *testing.T) {
+	t.Setenv("AI_PROVIDER_REGIONS", "ollama:EU, gpt4:us|eu, claude:, bogus, gemini:mars")
+
+	regions := providerRegionsFromEnv()
+	if got := regionsString(regions[ProviderOllama]); got != "eu" {
+		t.Fatalf("ollama regions = %q, want eu", got)
+	}
+	if got := regionsString(regions[ProviderGPT4]); got != "eu, us" {
+		t.Fatalf("gpt4 regions = %q, want eu, us", got)
+	}
+	if len(regions[ProviderClaude]) != 0 {
+		t.Fatalf("claude regions = %v, want untagged", regions[ProviderClaude])
+	}
+	if len(regions[ProviderGemini]) != 0 {
+		t.Fatalf("gemini regions = %v, want unknown region dropped", regions[ProviderGemini])
+	}
+	if got := regionsString(regions[ProviderGrok]); got != "us" {
+		t.Fatalf("grok regions = %q, want default us", got)
+	}
+	if _, ok := ParseRegion(" EU "); !ok {
+		t.Fatal("ParseRegion should normalize case and whitespace")
+	}
+}
@@ -0,0 +1,227 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter caps provider-supplied waits so a bogus header cannot park a
+// request for hours.
+const maxRetryAfter = 10 * time.Minute
+
+// maxRouterRetryWait is the longest the router waits to retry the same
+// provider. Longer waits go to fallback providers, or back to the caller when
+// fallback is disabled so it can switch providers itself.
+const maxRouterRetryWait = 20 * time.Second
+
+// RateLimitError is a provider rate-limit failure that carries the wait the
+// provider asked for. Its message keeps the provider's structured prefix and
+// appends a "[retry_after=...]" marker that survives string-only error paths.
+type RateLimitError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s [retry_after=%s]", e.Err.Error(), e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// withRetryAfter attaches the wait advertised by a provider's rate-limit
+// headers to err. err is returned unchanged when the headers carry no hint.
+func withRetryAfter(err error, header http.Header) error {
+	if err == nil {
+		return nil
+	}
+	wait, ok := parseRetryAfterHeaders(header, time.Now())
+	if !ok {
+		return err
+	}
+	return &RateLimitError{Err: err, RetryAfter: wait}
+}
+
+// parseRetryAfterHeaders reads Retry-After (seconds or HTTP date) and, when it
+// is absent, the longest of the provider reset headers: retry-after-ms,
+// x-ratelimit-reset (epoch or delta seconds), OpenAI's
+// x-ratelimit-reset-requests/-tokens ("6m0s") and Anthropic's
+// anthropic-ratelimit-*-reset (RFC 3339).
+func parseRetryAfterHeaders(header http.Header, now time.Time) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	if value := strings.TrimSpace(header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return clampRetryAfter(time.Duration(seconds * float64(time.Second))), true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return clampRetryAfter(at.Sub(now)), true
+		}
+	}
+
+	var (
+		longest time.Duration
+		found   bool
+	)
+	consider := func(wait time.Duration) {
+		if !found || wait > longest {
+			longest = wait
+		}
+		found = true
+	}
+	if value := strings.TrimSpace(header.Get("Retry-After-Ms")); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms >= 0 {
+			consider(time.Duration(ms * float64(time.Millisecond)))
+		}
+	}
+	if value := strings.TrimSpace(header.Get("X-Ratelimit-Reset")); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			// Values past 2001 in seconds are epoch timestamps, not deltas.
+			if seconds > 1e9 {
+				consider(time.Unix(int64(seconds), 0).Sub(now))
+			} else {
+				consider(time.Duration(seconds * float64(time.Second)))
+			}
+		}
+	}
+	for _, name := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if wait, err := time.ParseDuration(strings.TrimSpace(header.Get(name))); err == nil && wait >= 0 {
+			consider(wait)
+		}
+	}
+	for _, name := range []string{"Anthropic-Ratelimit-Requests-Reset", "Anthropic-Ratelimit-Tokens-Reset", "Anthropic-Ratelimit-Input-Tokens-Reset", "Anthropic-Ratelimit-Output-Tokens-Reset"} {
+		if at, err := time.Parse(time.RFC3339, strings.TrimSpace(header.Get(name))); err == nil {
+			consider(at.Sub(now))
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return clampRetryAfter(longest), true
+}
+
+func clampRetryAfter(wait time.Duration) time.Duration {
+	if wait < 0 {
+		return 0
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return wait
+}
+
+var retryAfterMarkerPattern = regexp.MustCompile(`\[retry_after=([0-9a-zµ.]+)\]`)
+
+// RetryAfterHint returns the wait a rate-limited provider asked for. It works
+// on wrapped errors and on errors flattened to strings by fmt.Errorf("%v").
+func RetryAfterHint(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	var rateLimited *RateLimitError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter, true
+	}
+	return RetryAfterHintFromMessage(err.Error())
+}
+
+// RetryAfterHintFromMessage parses the "[retry_after=...]" marker left by
+// RateLimitError in an error message.
+func RetryAfterHintFromMessage(message string) (time.Duration, bool) {
+	match := retryAfterMarkerPattern.FindStringSubmatch(message)
+	if match == nil {
+		return 0, false
+	}
+	wait, err := time.ParseDuration(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return clampRetryAfter(wait), true
+}
+
+// RetryBudget caps the total time a build may spend waiting to retry
+// provider calls. It is shared by every call made for the build. A nil budget
+// is unlimited.
+type RetryBudget struct {
+	mu    sync.Mutex
+	total time.Duration
+	spent time.Duration
+}
+
+// NewRetryBudget returns a budget allowing total retry waiting.
+func NewRetryBudget(total time.Duration) *RetryBudget {
+	return &RetryBudget{total: total}
+}
+
+// Reserve deducts wait from the budget, or reports false and deducts nothing
+// when wait does not fit.
+func (b *RetryBudget) Reserve(wait time.Duration) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spent+wait > b.total {
+		return false
+	}
+	b.spent += wait
+	return true
+}
+
+// Remaining returns the unspent wait time.
+func (b *RetryBudget) Remaining() time.Duration {
+	if b == nil {
+		return maxRetryAfter
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spent >= b.total {
+		return 0
+	}
+	return b.total - b.spent
+}
+
+// Spent returns the wait time already deducted.
+func (b *RetryBudget) Spent() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// retryDelay decides whether the router should retry provider after err and
+// how long to wait first. Rate-limited calls wait what the provider asked for;
+// transient network errors back off linearly. It reports false when the wait
+// exceeds maxRouterRetryWait or would not fit the request deadline or the
+// request's retry budget.
+func retryDelay(ctx context.Context, req *AIRequest, err error, attempt int) (time.Duration, bool) {
+	wait, hinted := RetryAfterHint(err)
+	if !hinted {
+		if !isTransientError(err) {
+			return 0, false
+		}
+		wait = time.Duration(attempt) * 3 * time.Second
+	}
+	if hinted && wait > maxRouterRetryWait {
+		return 0, false
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if wait+providerAttemptSafetyMargin(remaining) >= remaining {
+			return 0, false
+		}
+	}
+	if !req.RetryBudget.Reserve(wait) {
+		return 0, false
+	}
+	return wait, true
+}
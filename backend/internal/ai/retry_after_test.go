@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfterHeaders(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+		ok      bool
+	}{
+		{"seconds", map[string]string{"Retry-After": "7"}, 7 * time.Second, true},
+		{"http date", map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, 90 * time.Second, true},
+		{"milliseconds", map[string]string{"retry-after-ms": "1500"}, 1500 * time.Millisecond, true},
+		{"openai resets take the longest", map[string]string{"x-ratelimit-reset-requests": "1s", "x-ratelimit-reset-tokens": "6m0s"}, 6 * time.Minute, true},
+		{"anthropic reset", map[string]string{"anthropic-ratelimit-tokens-reset": now.Add(45 * time.Second).Format(time.RFC3339)}, 45 * time.Second, true},
+		{"epoch reset", map[string]string{"x-ratelimit-reset": strconv.FormatInt(now.Add(20*time.Second).Unix(), 10)}, 20 * time.Second, true},
+		{"clamped", map[string]string{"Retry-After": "86400"}, maxRetryAfter, true},
+		{"none", map[string]string{"Content-Type": "application/json"}, 0, false},
+	}
+	for _, tc := range cases {
+		header := http.Header{}
+		for key, value := range tc.headers {
+			header.Set(key, value)
+		}
+		got, ok := parseRetryAfterHeaders(header, now)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("%s: got %v, %v; want %v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRetryAfterHintSurvivesWrapping(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+	err := withRetryAfter(errors.New("RATE_LIMIT: Claude API rate limit exceeded"), header)
+	if RetryReasonCode(err) != "rate_limited" {
+		t.Fatalf("reason = %q, want rate_limited", RetryReasonCode(err))
+	}
+	for _, wrapped := range []error{err, fmt.Errorf("claude: %w", err), fmt.Errorf("claude: %v", err)} {
+		if wait, ok := RetryAfterHint(wrapped); !ok || wait != 3*time.Second {
+			t.Fatalf("hint for %q = %v, %v", wrapped, wait, ok)
+		}
+	}
+	if _, ok := RetryAfterHint(errors.New("RATE_LIMIT: no headers")); ok {
+		t.Fatal("errors without the marker carry no hint")
+	}
+}
+
+func TestGenerateSchedulesRetryFromRateLimitHint(t *testing.T) {
+	primaryCalls := 0
+	newRouter := func(wait time.Duration) *AIRouter {
+		primaryCalls = 0
+		return &AIRouter{
+			clients: map[AIProvider]AIClient{
+				ProviderGPT4: &routerStubClient{
+					generate: func(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+						primaryCalls++
+						if primaryCalls == 1 {
+							return nil, &RateLimitError{Err: errors.New("RATE_LIMIT: slow down"), RetryAfter: wait}
+						}
+						return &AIResponse{Provider: ProviderGPT4, Content: "ok"}, nil
+					},
+				},
+				ProviderClaude: &routerStubClient{},
+			},
+			config:       DefaultRouterConfig(),
+			healthStatus: map[AIProvider]string{ProviderGPT4: "ok", ProviderClaude: "ok"},
+			healthCheck:  map[AIProvider]bool{ProviderGPT4: true, ProviderClaude: true},
+		}
+	}
+
+	budget := NewRetryBudget(time.Second)
+	resp, err := newRouter(20*time.Millisecond).Generate(context.Background(), &AIRequest{
+		Provider: ProviderGPT4, Capability: CapabilityCodeGeneration, Prompt: "hi", DisableFallback: true, RetryBudget: budget,
+	})
+	if err != nil || resp.Provider != ProviderGPT4 || primaryCalls != 2 {
+		t.Fatalf("short waits should retry the same provider: resp=%+v err=%v calls=%d", resp, err, primaryCalls)
+	}
+	if budget.Spent() != 20*time.Millisecond {
+		t.Fatalf("budget spent = %v, want the advertised wait", budget.Spent())
+	}
+
+	started := time.Now()
+	resp, err = newRouter(time.Minute).Generate(context.Background(), &AIRequest{
+		Provider: ProviderGPT4, Capability: CapabilityCodeGeneration, Prompt: "hi",
+	})
+	if err != nil || resp.Provider != ProviderClaude || primaryCalls != 1 || time.Since(started) > 5*time.Second {
+		t.Fatalf("long waits should switch providers immediately: resp=%+v err=%v calls=%d", resp, err, primaryCalls)
+	}
+
+	_, err = newRouter(20*time.Millisecond).Generate(context.Background(), &AIRequest{
+		Provider: ProviderGPT4, Capability: CapabilityCodeGeneration, Prompt: "hi", DisableFallback: true, RetryBudget: NewRetryBudget(0),
+	})
+	if err == nil || primaryCalls != 1 {
+		t.Fatalf("an exhausted budget should return the rate limit to the caller: err=%v calls=%d", err, primaryCalls)
+	}
+}
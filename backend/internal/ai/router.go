@@ -44,8 +44,11 @@ type AIRouter struct {
 	sharedRates  providerRateLimitStore
 	mu           sync.RWMutex
 	healthCheck  map[AIProvider]bool
-	healthStatus map[AIProvider]string // "ok", "no_credits", "auth_error", "timeout", "error", "unknown"
-	healthDetail map[AIProvider]string // secret-redacted last health-check error message
+	healthStatus map[AIProvider]string   // "ok", "no_credits", "auth_error", "timeout", "error", "unknown"
+	healthDetail map[AIProvider]string   // secret-redacted last health-check error message
+	breakers     *circuitBreakers        // per-provider circuit breakers; nil disables them
+	regions      map[AIProvider][]Region // data residency regions each provider serves
+	regionPolicy RegionPolicy            // optional; resolves allowed regions per user
 }
 
 // GetConfiguredProviders returns provider clients that exist in this router,
@@ -193,6 +196,8 @@ func NewAIRouter(claudeKey, openAIKey, geminiKey string, extraKeys ...string) *A
 		sharedRates:  newProviderRateLimitStoreFromEnv(),
 		healthCheck:  make(map[AIProvider]bool),
 		healthStatus: make(map[AIProvider]string),
+		breakers:     newCircuitBreakers(DefaultCircuitBreakerConfig()),
+		regions:      providerRegionsFromEnv(),
 	}
 
 	// Start health monitoring
@@ -201,8 +206,22 @@ func NewAIRouter(claudeKey, openAIKey, geminiKey string, extraKeys ...string) *A
 	return router
 }
 
-// Generate routes an AI request to the optimal provider
+// Generate routes an AI request to the optimal provider. Requests limited to
+// regions, by the caller or the user's organization policy, only go to
+// providers tagged with one of them; the serving region is recorded on the
+// response.
 func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	if err := r.applyRegionPolicy(ctx, req); err != nil {
+		return nil, err
+	}
+	response, err := r.generate(ctx, req)
+	if err == nil {
+		r.tagResponseRegion(req, response)
+	}
+	return response, err
+}
+
+func (r *AIRouter) generate(ctx context.Context, req *AIRequest) (*AIResponse, error) {
 	// Validate input lengths to prevent abuse and excessive costs
 	if len(req.Prompt) > MaxPromptLength {
 		return nil, fmt.Errorf("prompt exceeds maximum length of %d characters", MaxPromptLength)
@@ -263,6 +282,9 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 		// Try fallback providers
 		fallbacks := r.config.FallbackOrder[provider]
 		for _, fallbackProvider := range fallbacks {
+			if !r.providerAllowedByRegion(primaryReq, fallbackProvider) {
+				continue
+			}
 			if r.checkRateLimit(fallbackProvider) {
 				if fallbackClient, exists := r.clients[fallbackProvider]; exists {
 					log.Printf("Rate limited for %s, using fallback %s", provider, fallbackProvider)
@@ -271,7 +293,8 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 						log.Printf("Skipping fallback provider %s: estimated cost %.6f exceeds threshold %.6f", fallbackProvider, estimatedCost, threshold)
 						continue
 					}
-					return r.generateWithAttemptBudget(ctx, fallbackProvider, fallbackClient, fallbackReq, len(fallbacks))
+					response, err := r.generateWithAttemptBudget(ctx, fallbackProvider, fallbackClient, fallbackReq, len(fallbacks))
+					return withRetryReasons(response, []string{"rate_limited"}), err
 				}
 			}
 		}
@@ -280,29 +303,33 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 
 	// Attempt to generate with primary provider.
 	// Retry up to 2 extra times on transient network errors (important for
-	// Ollama-only mode where there is no fallback provider).
+	// Ollama-only mode where there is no fallback provider) and on rate limits
+	// whose advertised wait is short; see retryDelay.
 	const maxRetries = 2
 	var response *AIResponse
 	var genErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(attempt) * 3 * time.Second
-			log.Printf("Retrying provider %s after transient error (attempt %d/%d, backoff %v): %v",
-				provider, attempt, maxRetries, backoff, genErr)
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
+	var retryReasons []string
+	for attempt := 0; ; attempt++ {
 		attemptsRemaining := 1
 		if !req.DisableFallback {
 			attemptsRemaining += len(r.config.FallbackOrder[provider])
 		}
 		response, genErr = r.generateWithAttemptBudget(ctx, provider, client, primaryReq, attemptsRemaining)
-		if genErr == nil || !isTransientError(genErr) {
+		if genErr == nil || attempt >= maxRetries {
+			break
+		}
+		backoff, retry := retryDelay(ctx, req, genErr, attempt+1)
+		if !retry {
 			break
 		}
+		retryReasons = append(retryReasons, RetryReasonCode(genErr))
+		log.Printf("Retrying provider %s (attempt %d/%d, backoff %v): %v",
+			provider, attempt+1, maxRetries, backoff, genErr)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
 	}
 	if genErr != nil {
 		errStr := genErr.Error()
@@ -320,6 +347,7 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 
 		// Collect all errors for better reporting
 		failedProviders := []string{fmt.Sprintf("%s: %s", provider, errStr)}
+		retryReasons = append(retryReasons, RetryReasonCode(genErr))
 		if req.DisableFallback {
 			return nil, fmt.Errorf("%s: %s", provider, errStr)
 		}
@@ -327,6 +355,9 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 		// Try fallback providers
 		fallbacks := r.config.FallbackOrder[provider]
 		for idx, fallbackProvider := range fallbacks {
+			if !r.providerAllowedByRegion(primaryReq, fallbackProvider) {
+				continue
+			}
 			if fallbackClient, exists := r.clients[fallbackProvider]; exists {
 				if r.checkRateLimit(fallbackProvider) && r.isHealthyOrUnknown(fallbackProvider) {
 					log.Printf("Falling back to provider %s", fallbackProvider)
@@ -338,8 +369,9 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 					}
 					fallbackResponse, fallbackErr := r.generateWithAttemptBudget(ctx, fallbackProvider, fallbackClient, fallbackReq, len(fallbacks)-idx)
 					if fallbackErr == nil {
-						return fallbackResponse, nil
+						return withRetryReasons(fallbackResponse, retryReasons), nil
 					}
+					retryReasons = append(retryReasons, RetryReasonCode(fallbackErr))
 					fallbackErrStr := fallbackErr.Error()
 					log.Printf("Fallback provider %s also failed: %v", fallbackProvider, fallbackErr)
 					failedProviders = append(failedProviders, fmt.Sprintf("%s: %s", fallbackProvider, fallbackErrStr))
@@ -360,7 +392,20 @@ func (r *AIRouter) Generate(ctx context.Context, req *AIRequest) (*AIResponse, e
 		return nil, fmt.Errorf("ALL_PROVIDERS_FAILED: %s", strings.Join(failedProviders, "; "))
 	}
 
-	return response, nil
+	return withRetryReasons(response, retryReasons), nil
+}
+
+// withRetryReasons records why the router retried or fell back before this
+// response, as RetryReasonCode values under Metadata["retry_reasons"].
+func withRetryReasons(response *AIResponse, reasons []string) *AIResponse {
+	if response == nil || len(reasons) == 0 {
+		return response
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["retry_reasons"] = append([]string(nil), reasons...)
+	return response
 }
 
 // isTransientError returns true for errors that are safe to retry: network
@@ -503,7 +548,18 @@ func (r *AIRouter) generateWithAttemptBudget(
 		defer cancel()
 	}
 
-	return client.Generate(attemptCtx, req)
+	if !r.breakers.acquire(provider) {
+		return nil, circuitOpenError(provider)
+	}
+	started := time.Now()
+	response, err := client.Generate(attemptCtx, req)
+	if ctx.Err() != nil {
+		// The caller gave up; the outcome says nothing about the provider.
+		r.breakers.abandon(provider)
+	} else {
+		r.breakers.record(provider, time.Since(started), err)
+	}
+	return response, err
 }
 
 // requestForProvider prepares a provider-specific request copy.
@@ -535,8 +591,16 @@ func (r *AIRouter) selectProvider(req *AIRequest) (AIProvider, error) {
 			}
 		} else {
 			status := r.healthStatus[requested]
-			knownBad := status == "auth_error" || (status == "no_credits" && !isOpenRouterFreeRequest(req, requested))
-			if knownBad {
+			if !r.breakers.permits(requested) {
+				status = "circuit_open"
+			}
+			knownBad := status == "circuit_open" || status == "auth_error" || (status == "no_credits" && !isOpenRouterFreeRequest(req, requested))
+			if !r.providerAllowedByRegion(req, requested) {
+				if req.DisableFallback {
+					return "", &RegionUnavailableError{Allowed: req.AllowedRegions, Requested: requested}
+				}
+				log.Printf("Provider %s does not serve %s; trying fallback", requested, regionsString(req.AllowedRegions))
+			} else if knownBad {
 				if req.DisableFallback {
 					return "", fmt.Errorf("provider %s unhealthy: %s", requested, status)
 				}
@@ -553,7 +617,7 @@ func (r *AIRouter) selectProvider(req *AIRequest) (AIProvider, error) {
 		}
 		if fallbacks, ok := r.config.FallbackOrder[requested]; ok {
 			for _, provider := range fallbacks {
-				if r.isAvailable(provider) && r.isHealthyOrUnknown(provider) {
+				if r.isAvailable(provider) && r.isHealthyOrUnknown(provider) && r.providerAllowedByRegion(req, provider) {
 					if allowed, estimatedCost, threshold := r.providerAllowedByCost(req, provider); !allowed {
 						log.Printf("Skipping provider %s during explicit fallback: estimated cost %.6f exceeds threshold %.6f", provider, estimatedCost, threshold)
 						continue
@@ -576,7 +640,7 @@ func (r *AIRouter) selectProvider(req *AIRequest) (AIProvider, error) {
 	}
 
 	// Check if default provider is healthy, available, and within cost limits.
-	if r.isHealthyOrUnknown(defaultProvider) && r.isAvailable(defaultProvider) {
+	if r.isHealthyOrUnknown(defaultProvider) && r.isAvailable(defaultProvider) && r.providerAllowedByRegion(req, defaultProvider) {
 		if allowed, estimatedCost, threshold := r.providerAllowedByCost(req, defaultProvider); allowed {
 			return defaultProvider, nil
 		} else {
@@ -587,7 +651,7 @@ func (r *AIRouter) selectProvider(req *AIRequest) (AIProvider, error) {
 	// If default provider is not available or is over threshold, try fallbacks.
 	fallbacks := r.config.FallbackOrder[defaultProvider]
 	for _, provider := range fallbacks {
-		if r.isHealthyOrUnknown(provider) && r.isAvailable(provider) {
+		if r.isHealthyOrUnknown(provider) && r.isAvailable(provider) && r.providerAllowedByRegion(req, provider) {
 			if allowed, estimatedCost, threshold := r.providerAllowedByCost(req, provider); !allowed {
 				log.Printf("Skipping provider %s during fallback selection: estimated cost %.6f exceeds threshold %.6f", provider, estimatedCost, threshold)
 				continue
@@ -652,8 +716,13 @@ func (r *AIRouter) selectByLoadBalancing(req *AIRequest) (AIProvider, error) {
 	totalWeight := 0.0
 
 	// Collect healthy providers and their weights
+	regionExcluded := false
 	for provider, weight := range r.config.LoadBalancing {
 		if r.isHealthyOrUnknown(provider) && r.isAvailable(provider) {
+			if !r.providerAllowedByRegion(req, provider) {
+				regionExcluded = true
+				continue
+			}
 			if allowed, estimatedCost, threshold := r.providerAllowedByCost(req, provider); !allowed {
 				log.Printf("Skipping load-balanced provider %s: estimated cost %.6f exceeds threshold %.6f", provider, estimatedCost, threshold)
 				continue
@@ -664,6 +733,9 @@ func (r *AIRouter) selectByLoadBalancing(req *AIRequest) (AIProvider, error) {
 	}
 
 	if len(healthyProviders) == 0 {
+		if regionExcluded {
+			return "", &RegionUnavailableError{Allowed: req.AllowedRegions}
+		}
 		return "", fmt.Errorf("no healthy providers available")
 	}
 
@@ -738,7 +810,11 @@ func (r *AIRouter) isHealthy(provider AIProvider) bool {
 }
 
 // isHealthyOrUnknown treats missing health as healthy to avoid blocking requests during startup.
+// A provider whose circuit breaker is open is never healthy.
 func (r *AIRouter) isHealthyOrUnknown(provider AIProvider) bool {
+	if !r.breakers.permits(provider) {
+		return false
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -828,6 +904,36 @@ func classifyProviderError(err error) string {
 	}
 }
 
+// RetryReasonCode reduces a provider error to a short reason code for
+// telemetry: rate_limited, timeout, network, no_credits, auth_error,
+// circuit_open, cancelled or provider_error.
+func RetryReasonCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, errCircuitOpen) {
+		return "circuit_open"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "cancelled"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.HasPrefix(msg, "rate_limit") || strings.Contains(msg, "rate limit") || strings.Contains(msg, " 429") {
+		return "rate_limited"
+	}
+	if isTransientError(err) {
+		return "network"
+	}
+	switch class := classifyProviderError(err); class {
+	case "no_credits", "auth_error", "timeout":
+		return class
+	}
+	return "provider_error"
+}
+
 // performHealthChecks checks health of all providers
 func (r *AIRouter) performHealthChecks() {
 	if r == nil {
@@ -64,6 +64,30 @@ func TestClassifyProviderErrorMatrix(t *testing.T) {
 	}
 }
 
+func TestRetryReasonCodeMatrix(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{context.Canceled, "cancelled"},
+		{context.DeadlineExceeded, "timeout"},
+		{errors.New("RATE_LIMIT: Gemini API rate limit exceeded (status=429)"), "rate_limited"},
+		{errors.New("rate limit exceeded for provider claude"), "rate_limited"},
+		{errors.New("read tcp: connection reset by peer"), "network"},
+		{errors.New("QUOTA_EXCEEDED: Gemini API quota exhausted"), "no_credits"},
+		{errors.New("UNAUTHORIZED: invalid API key"), "auth_error"},
+		{errors.New("API_ERROR: request failed (status=520)"), "provider_error"},
+	}
+	for _, tc := range cases {
+		if got := RetryReasonCode(tc.err); got != tc.want {
+			t.Fatalf("RetryReasonCode(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
 func TestPerformHealthChecksSurfacesRedactedDetail(t *testing.T) {
 	t.Parallel()
 
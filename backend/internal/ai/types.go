@@ -9,14 +9,14 @@ import (
 type AIProvider string
 
 const (
-	ProviderClaude      AIProvider = "claude"
-	ProviderGPT4        AIProvider = "gpt4"
-	ProviderGemini      AIProvider = "gemini"
-	ProviderGrok        AIProvider = "grok"
-	ProviderOllama      AIProvider = "ollama"
-	ProviderDeepSeek    AIProvider = "deepseek"
-	ProviderGLM         AIProvider = "glm"
-	ProviderOpenRouter  AIProvider = "openrouter"
+	ProviderClaude     AIProvider = "claude"
+	ProviderGPT4       AIProvider = "gpt4"
+	ProviderGemini     AIProvider = "gemini"
+	ProviderGrok       AIProvider = "grok"
+	ProviderOllama     AIProvider = "ollama"
+	ProviderDeepSeek   AIProvider = "deepseek"
+	ProviderGLM        AIProvider = "glm"
+	ProviderOpenRouter AIProvider = "openrouter"
 )
 
 // AICapability represents different AI use cases
@@ -37,9 +37,11 @@ const (
 
 // AIRequest represents a request to an AI provider
 type AIRequest struct {
-	ID                 string                 `json:"id"`
-	Provider           AIProvider             `json:"provider"`
-	Model              string                 `json:"model,omitempty"` // Explicit model override (e.g. "grok-3", "claude-sonnet-4-6")
+	ID       string     `json:"id"`
+	Provider AIProvider `json:"provider"`
+	Model    string     `json:"model,omitempty"` // Explicit model override (e.g. "grok-3", "claude-sonnet-4-6")
+	// ModelScope selects a BYOK per-capability model preference when Model is empty.
+	ModelScope         string                 `json:"model_scope,omitempty"`
 	Capability         AICapability           `json:"capability"`
 	Prompt             string                 `json:"prompt"`
 	Code               string                 `json:"code,omitempty"`
@@ -62,6 +64,20 @@ type AIRequest struct {
 	// orchestration paths use this so they can apply scorecard-aware fallback
 	// with correct telemetry instead of burning one request deadline internally.
 	DisableFallback bool `json:"disable_fallback,omitempty"`
+	// RetryBudget, when set, is charged for every retry wait on this request
+	// and shared across a build's calls. Nil means only the deadline limits waits.
+	RetryBudget *RetryBudget `json:"-"`
+	// Stop ends generation at the first of these sequences on providers
+	// that support stop sequences; extras beyond a provider's limit are dropped.
+	Stop []string `json:"stop,omitempty"`
+	// FIM asks providers with a fill-in-the-middle endpoint to complete
+	// between FIM.Prefix and FIM.Suffix when the selected model supports
+	// it. Other providers ignore it and answer Prompt.
+	FIM *FIMInput `json:"fim,omitempty"`
+	// AllowedRegions limits routing to providers tagged with one of these
+	// data residency regions. Empty means unrestricted, unless the router's
+	// region policy restricts the request's user.
+	AllowedRegions []Region `json:"allowed_regions,omitempty"`
 }
 
 // GetCacheKey generates a cache key for the request
@@ -191,7 +207,7 @@ func DefaultRouterConfig() *RouterConfig {
 			ProviderGLM:        0.02,
 		},
 		RateLimits: map[AIProvider]int{
-			ProviderOpenRouter: 200,  // OpenRouter handles rate limiting per-model internally
+			ProviderOpenRouter: 200, // OpenRouter handles rate limiting per-model internally
 			ProviderClaude:     100,
 			ProviderGPT4:       80,
 			ProviderGemini:     120,
@@ -0,0 +1,152 @@
+package analytics
+
+import "time"
+
+// AIUsageDaily is a per-user rollup of AIRequest rows for one UTC day,
+// keyed by provider, model and the BYOK key that served them (0 for
+// platform keys). The aggregation job rewrites a day's rows
+// wholesale, so the table can always be rebuilt from ai_requests.
+type AIUsageDaily struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_ai_usage_daily_key,priority:1" json:"user_id"`
+	DayKey         string    `gorm:"not null;size:10;uniqueIndex:idx_ai_usage_daily_key,priority:2" json:"day_key"`
+	Provider       string    `gorm:"not null;size:50;uniqueIndex:idx_ai_usage_daily_key,priority:3" json:"provider"`
+	Model          string    `gorm:"not null;size:100;default:'';uniqueIndex:idx_ai_usage_daily_key,priority:4" json:"model"`
+	APIKeyID       uint      `gorm:"not null;default:0;uniqueIndex:idx_ai_usage_daily_key,priority:5" json:"api_key_id"`
+	RequestCount   int64     `gorm:"not null;default:0" json:"request_count"`
+	ErrorCount     int64     `gorm:"not null;default:0" json:"error_count"`
+	TokensUsed     int64     `gorm:"not null;default:0" json:"tokens_used"`
+	Cost           float64   `gorm:"not null;default:0;type:numeric(12,6)" json:"cost"`
+	TotalLatencyMs int64     `gorm:"not null;default:0" json:"total_latency_ms"`
+}
+
+func (AIUsageDaily) TableName() string { return "ai_usage_daily" }
+
+// UsageRow is one grouped row returned by analytics queries.
+type UsageRow struct {
+	DayKey       string  `json:"day,omitempty"`
+	Provider     string  `json:"provider,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	Key          string  `json:"key,omitempty"`        // "platform" or "byok" when grouped by key
+	APIKeyID     *uint   `json:"api_key_id,omitempty"` // the BYOK key, when Key is "byok"
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	Tokens       int64   `json:"tokens"`
+	Cost         float64 `json:"cost"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+}
+
+// UsageTotals summarizes every row matched by a query.
+type UsageTotals struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	Tokens       int64   `json:"tokens"`
+	Cost         float64 `json:"cost"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+}
+
+// QueryOpts controls the range and grouping of an analytics query.
+type QueryOpts struct {
+	UserID   uint
+	From     time.Time // inclusive, UTC day
+	To       time.Time // inclusive, UTC day
+	GroupBy  []string  // any of "day", "provider", "model", "key"
+	Provider string
+	Model    string
+}
+
+// PlatformDailyMetric is the platform-wide rollup for one UTC day. The
+// nightly warehouse job rewrites recent days because activation keeps
+// changing for ActivationWindowDays after signup.
+type PlatformDailyMetric struct {
+	ID             uint      `gorm:"primarykey" json:"-"`
+	CreatedAt      time.Time `json:"-"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	DayKey         string    `gorm:"not null;size:10;uniqueIndex" json:"day"`
+	Signups        int64     `gorm:"not null;default:0" json:"signups"`
+	ActivatedUsers int64     `gorm:"not null;default:0" json:"activated_users"`
+	ActivationRate float64   `gorm:"not null;default:0" json:"activation_rate"`
+	ActiveUsers    int64     `gorm:"not null;default:0" json:"active_users"`
+	PaidUsers      int64     `gorm:"not null;default:0" json:"paid_users"`
+	Builds         int64     `gorm:"not null;default:0" json:"builds"`
+	BuildsFailed   int64     `gorm:"not null;default:0" json:"builds_failed"`
+	AIRequests     int64     `gorm:"not null;default:0" json:"ai_requests"`
+	AICostUSD      float64   `gorm:"not null;default:0;type:numeric(14,6)" json:"ai_cost_usd"`
+	RevenueUSD     float64   `gorm:"not null;default:0;type:numeric(14,2)" json:"revenue_usd"`
+	GrossMarginUSD float64   `gorm:"not null;default:0;type:numeric(14,6)" json:"gross_margin_usd"`
+}
+
+func (PlatformDailyMetric) TableName() string { return "platform_daily_metrics" }
+
+// PlanDailyMetric breaks one UTC day's builds and AI cost down by the
+// users' subscription plan.
+type PlanDailyMetric struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	DayKey      string    `gorm:"not null;size:10;uniqueIndex:idx_plan_daily_key,priority:1" json:"day"`
+	Plan        string    `gorm:"not null;size:20;uniqueIndex:idx_plan_daily_key,priority:2" json:"plan"`
+	Builds      int64     `gorm:"not null;default:0" json:"builds"`
+	Builders    int64     `gorm:"not null;default:0" json:"builders"`
+	AIRequests  int64     `gorm:"not null;default:0" json:"ai_requests"`
+	AICostUSD   float64   `gorm:"not null;default:0;type:numeric(14,6)" json:"ai_cost_usd"`
+	RevenueUSD  float64   `gorm:"not null;default:0;type:numeric(14,2)" json:"revenue_usd"`
+	ActiveUsers int64     `gorm:"not null;default:0" json:"active_users"`
+}
+
+func (PlanDailyMetric) TableName() string { return "plan_daily_metrics" }
+
+// RetentionCohort is the share of one weekly signup cohort that was active
+// WeekOffset weeks after signing up. Cohort weeks start on Monday, UTC.
+type RetentionCohort struct {
+	ID            uint      `gorm:"primarykey" json:"-"`
+	CreatedAt     time.Time `json:"-"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	CohortWeek    string    `gorm:"not null;size:10;uniqueIndex:idx_retention_cohort_key,priority:1" json:"cohort_week"`
+	WeekOffset    int       `gorm:"not null;uniqueIndex:idx_retention_cohort_key,priority:2" json:"week_offset"`
+	CohortSize    int64     `gorm:"not null;default:0" json:"cohort_size"`
+	RetainedUsers int64     `gorm:"not null;default:0" json:"retained_users"`
+	RetentionRate float64   `gorm:"not null;default:0" json:"retention_rate"`
+}
+
+func (RetentionCohort) TableName() string { return "retention_cohorts" }
+
+// WarehouseExport records one table partition written to one sink.
+type WarehouseExport struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	Sink       string    `gorm:"not null;size:20;index" json:"sink"`
+	Table      string    `gorm:"column:table_name;not null;size:64" json:"table"`
+	Partition  string    `gorm:"column:partition_day;size:10" json:"partition,omitempty"`
+	Location   string    `gorm:"size:512" json:"location"`
+	Rows       int       `gorm:"column:row_count;not null;default:0" json:"rows"`
+	Bytes      int       `gorm:"column:size_bytes;not null;default:0" json:"bytes"`
+	Status     string    `gorm:"not null;size:16" json:"status"` // succeeded, failed
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMs int64     `gorm:"not null;default:0" json:"duration_ms"`
+}
+
+func (WarehouseExport) TableName() string { return "analytics_exports" }
+
+// TrendQuery selects a date range of warehouse metrics.
+type TrendQuery struct {
+	From time.Time // inclusive, UTC day
+	To   time.Time // inclusive, UTC day
+}
+
+// TrendTotals sums platform daily metrics over a range.
+type TrendTotals struct {
+	Signups        int64   `json:"signups"`
+	ActivatedUsers int64   `json:"activated_users"`
+	ActivationRate float64 `json:"activation_rate"`
+	Builds         int64   `json:"builds"`
+	BuildsFailed   int64   `json:"builds_failed"`
+	AIRequests     int64   `json:"ai_requests"`
+	AICostUSD      float64 `json:"ai_cost_usd"`
+	RevenueUSD     float64 `json:"revenue_usd"`
+	GrossMarginUSD float64 `json:"gross_margin_usd"`
+}
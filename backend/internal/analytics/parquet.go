@@ -0,0 +1,320 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ColumnKind is the physical type of a warehouse column.
+type ColumnKind int
+
+const (
+	ColumnString ColumnKind = iota
+	ColumnInt64
+	ColumnFloat64
+	ColumnDate // a YYYY-MM-DD day key, stored as a parquet DATE
+)
+
+// Column describes one column of an exported table.
+type Column struct {
+	Name string
+	Kind ColumnKind
+}
+
+// Parquet physical types, encodings and converted types used by the writer.
+// See parquet-format's parquet.thrift.
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+
+	parquetConvertedUTF8 = 0
+	parquetConvertedDate = 6
+)
+
+var parquetMagic = []byte("PAR1")
+
+// EncodeParquet writes rows as a single row group parquet file with
+// uncompressed, plain encoded, required columns. Values in each row follow
+// the order of columns: string for ColumnString and ColumnDate, int64 for
+// ColumnInt64 and float64 for ColumnFloat64.
+func EncodeParquet(columns []Column, rows [][]any) ([]byte, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("analytics: parquet needs at least one column")
+	}
+	var out bytes.Buffer
+	out.Write(parquetMagic)
+
+	chunks := make([]parquetChunk, 0, len(columns))
+	for i, col := range columns {
+		values, err := encodePlainColumn(col, i, rows)
+		if err != nil {
+			return nil, err
+		}
+		header := newThriftWriter()
+		header.structBegin()
+		header.fieldI32(1, parquetDataPage)
+		header.fieldI32(2, int32(len(values)))
+		header.fieldI32(3, int32(len(values)))
+		header.fieldStructBegin(5)
+		header.fieldI32(1, int32(len(rows)))
+		header.fieldI32(2, parquetPlain)
+		header.fieldI32(3, parquetRLE)
+		header.fieldI32(4, parquetRLE)
+		header.structEnd()
+		header.structEnd()
+
+		offset := int64(out.Len())
+		out.Write(header.bytes())
+		out.Write(values)
+		chunks = append(chunks, parquetChunk{
+			column: col,
+			offset: offset,
+			size:   int64(out.Len()) - offset,
+		})
+	}
+
+	footer := encodeParquetFooter(columns, chunks, int64(len(rows)))
+	out.Write(footer)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	out.Write(length[:])
+	out.Write(parquetMagic)
+	return out.Bytes(), nil
+}
+
+type parquetChunk struct {
+	column Column
+	offset int64
+	size   int64
+}
+
+func encodePlainColumn(col Column, index int, rows [][]any) ([]byte, error) {
+	var buf bytes.Buffer
+	var scratch [8]byte
+	for r, row := range rows {
+		if index >= len(row) {
+			return nil, fmt.Errorf("analytics: row %d has no value for column %s", r, col.Name)
+		}
+		value := row[index]
+		switch col.Kind {
+		case ColumnString:
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("analytics: column %s wants a string, got %T", col.Name, value)
+			}
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+			buf.Write(scratch[:4])
+			buf.WriteString(s)
+		case ColumnDate:
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("analytics: column %s wants a day key, got %T", col.Name, value)
+			}
+			day, err := time.Parse(dayKeyLayout, s)
+			if err != nil {
+				return nil, fmt.Errorf("analytics: column %s: %w", col.Name, err)
+			}
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(int32(day.Unix()/86400)))
+			buf.Write(scratch[:4])
+		case ColumnInt64:
+			var n int64
+			switch v := value.(type) {
+			case int64:
+				n = v
+			case int:
+				n = int64(v)
+			default:
+				return nil, fmt.Errorf("analytics: column %s wants an integer, got %T", col.Name, value)
+			}
+			binary.LittleEndian.PutUint64(scratch[:], uint64(n))
+			buf.Write(scratch[:])
+		case ColumnFloat64:
+			f, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("analytics: column %s wants a float64, got %T", col.Name, value)
+			}
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(f))
+			buf.Write(scratch[:])
+		default:
+			return nil, fmt.Errorf("analytics: column %s has unknown kind %d", col.Name, col.Kind)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func parquetPhysicalType(kind ColumnKind) int32 {
+	switch kind {
+	case ColumnInt64:
+		return parquetInt64
+	case ColumnFloat64:
+		return parquetDouble
+	case ColumnDate:
+		return parquetInt32
+	default:
+		return parquetByteArray
+	}
+}
+
+// encodeParquetFooter writes the FileMetaData struct
+func encodeParquetFooter(columns []Column, chunks []parquetChunk, numRows int64) []byte {
+	w := newThriftWriter()
+	w.structBegin()
+	w.fieldI32(1, 1)
+
+	// Schema: the root element followed by one leaf per column
+	w.fieldListBegin(2, thriftStruct, len(columns)+1)
+	w.structBegin()
+	w.fieldBinary(4, "schema")
+	w.fieldI32(5, int32(len(columns)))
+	w.structEnd()
+	for _, col := range columns {
+		w.structBegin()
+		w.fieldI32(1, parquetPhysicalType(col.Kind))
+		w.fieldI32(3, parquetRequired)
+		w.fieldBinary(4, col.Name)
+		switch col.Kind {
+		case ColumnString:
+			w.fieldI32(6, parquetConvertedUTF8)
+		case ColumnDate:
+			w.fieldI32(6, parquetConvertedDate)
+		}
+		w.structEnd()
+	}
+
+	w.fieldI64(3, numRows)
+
+	var total int64
+	for _, chunk := range chunks {
+		total += chunk.size
+	}
+	w.fieldListBegin(4, thriftStruct, 1)
+	w.structBegin()
+	w.fieldListBegin(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		w.structBegin()
+		w.fieldI64(2, chunk.offset)
+		w.fieldStructBegin(3)
+		w.fieldI32(1, parquetPhysicalType(chunk.column.Kind))
+		w.fieldListBegin(2, thriftI32, 2)
+		w.writeVarint(zigzag32(parquetPlain))
+		w.writeVarint(zigzag32(parquetRLE))
+		w.fieldListBegin(3, thriftBinary, 1)
+		w.writeBinary(chunk.column.Name)
+		w.fieldI32(4, parquetUncompressed)
+		w.fieldI64(5, numRows)
+		w.fieldI64(6, chunk.size)
+		w.fieldI64(7, chunk.size)
+		w.fieldI64(9, chunk.offset)
+		w.structEnd()
+		w.structEnd()
+	}
+	w.fieldI64(2, total)
+	w.fieldI64(3, numRows)
+	w.structEnd()
+
+	w.fieldBinary(6, "apex-build analytics")
+	w.structEnd()
+	return w.bytes()
+}
+
+// Thrift compact protocol type IDs
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter is the subset of the Thrift compact protocol needed for
+// parquet page headers and file metadata.
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+func (w *thriftWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) structBegin() {
+	w.lastField = append(w.lastField, 0)
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.lastField[len(w.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.writeVarint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) fieldI32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.writeVarint(zigzag32(v))
+}
+
+func (w *thriftWriter) fieldI64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.writeVarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) fieldBinary(id int16, s string) {
+	w.fieldHeader(id, thriftBinary)
+	w.writeBinary(s)
+}
+
+func (w *thriftWriter) fieldStructBegin(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.structBegin()
+}
+
+// fieldListBegin writes a list header; the caller writes size elements
+// right after it
+func (w *thriftWriter) fieldListBegin(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elemType)
+	w.writeVarint(uint64(size))
+}
+
+func (w *thriftWriter) writeBinary(s string) {
+	w.writeVarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) writeVarint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	w.buf.Write(scratch[:n])
+}
+
+func zigzag32(v int32) uint64 {
+	return uint64(uint32((v << 1) ^ (v >> 31)))
+}
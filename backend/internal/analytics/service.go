@@ -0,0 +1,334 @@
+// Package analytics rolls AIRequest history up into per-user daily usage
+// aggregates and serves them to the /ai/analytics dashboard.
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	dayKeyLayout = "2006-01-02"
+
+	// DefaultRollupInterval is how often the aggregation job refreshes the
+	// current and previous day.
+	DefaultRollupInterval = 10 * time.Minute
+
+	// MaxQueryDays bounds a single analytics query.
+	MaxQueryDays = 366
+)
+
+var validGroupColumns = map[string]string{
+	"day":      "day_key",
+	"provider": "provider",
+	"model":    "model",
+	"key":      "api_key_id",
+}
+
+// Key labels for rows grouped by key
+const (
+	KeyPlatform = "platform"
+	KeyBYOK     = "byok"
+)
+
+// Service aggregates and queries AI usage analytics.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new analytics Service backed by the given database.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// RollupDay recomputes the ai_usage_daily rows for the UTC day containing
+// day from the raw ai_requests table. Rows for the day are replaced in a
+// single transaction so readers never see a half-written day.
+func (s *Service) RollupDay(ctx context.Context, day time.Time) (int, error) {
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("analytics: database unavailable")
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+	dayKey := start.Format(dayKeyLayout)
+
+	var groups []struct {
+		UserID         uint
+		Provider       string
+		Model          string
+		APIKeyID       uint
+		RequestCount   int64
+		ErrorCount     int64
+		TokensUsed     int64
+		Cost           float64
+		TotalLatencyMs int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.AIRequest{}).
+		Select("user_id, provider, COALESCE(model, '') as model, COALESCE(api_key_id, 0) as api_key_id, "+
+			"COUNT(*) as request_count, "+
+			"COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as error_count, "+
+			"COALESCE(SUM(tokens_used), 0) as tokens_used, "+
+			"COALESCE(SUM(cost), 0) as cost, "+
+			"COALESCE(SUM(duration), 0) as total_latency_ms").
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("user_id, provider, COALESCE(model, ''), COALESCE(api_key_id, 0)").
+		Scan(&groups).Error; err != nil {
+		return 0, fmt.Errorf("analytics: rollup query failed: %w", err)
+	}
+
+	rows := make([]AIUsageDaily, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, AIUsageDaily{
+			UserID:         g.UserID,
+			DayKey:         dayKey,
+			Provider:       g.Provider,
+			Model:          g.Model,
+			APIKeyID:       g.APIKeyID,
+			RequestCount:   g.RequestCount,
+			ErrorCount:     g.ErrorCount,
+			TokensUsed:     g.TokensUsed,
+			Cost:           g.Cost,
+			TotalLatencyMs: g.TotalLatencyMs,
+		})
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day_key = ?", dayKey).Delete(&AIUsageDaily{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("analytics: rollup write failed: %w", err)
+	}
+	return len(rows), nil
+}
+
+// Start launches the periodic aggregation job. Each tick refreshes today and
+// yesterday so late-arriving requests around midnight are still counted.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if s == nil || s.db == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultRollupInterval
+	}
+	go func() {
+		log.Printf("[ai_analytics] rollup job started interval=%s", interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			now := time.Now().UTC()
+			for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+				if _, err := s.RollupDay(ctx, day); err != nil {
+					log.Printf("[ai_analytics] rollup %s failed: %v", day.Format(dayKeyLayout), err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				log.Printf("[ai_analytics] rollup job stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// NormalizeGroupBy parses a comma-separated group_by value, keeping only
+// known dimensions in a stable day → provider → model → key order.
+func NormalizeGroupBy(raw string) []string {
+	requested := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if _, ok := validGroupColumns[part]; ok {
+			requested[part] = true
+		}
+	}
+	if len(requested) == 0 {
+		return []string{"day", "provider", "model"}
+	}
+	out := make([]string, 0, len(requested))
+	for _, dim := range []string{"day", "provider", "model", "key"} {
+		if requested[dim] {
+			out = append(out, dim)
+		}
+	}
+	return out
+}
+
+// Query returns grouped usage rows plus totals for the requested range.
+func (s *Service) Query(ctx context.Context, opts QueryOpts) ([]UsageRow, UsageTotals, error) {
+	if s == nil || s.db == nil {
+		return nil, UsageTotals{}, fmt.Errorf("analytics: database unavailable")
+	}
+	if opts.UserID == 0 {
+		return nil, UsageTotals{}, fmt.Errorf("analytics: user id is required")
+	}
+	groupBy := opts.GroupBy
+	if len(groupBy) == 0 {
+		groupBy = NormalizeGroupBy("")
+	}
+
+	selects := make([]string, 0, len(groupBy)+6)
+	groups := make([]string, 0, len(groupBy))
+	orders := make([]string, 0, len(groupBy))
+	byKey := false
+	for _, dim := range groupBy {
+		col, ok := validGroupColumns[dim]
+		if !ok {
+			continue
+		}
+		selects = append(selects, col)
+		groups = append(groups, col)
+		switch dim {
+		case "day":
+			orders = append(orders, col+" ASC")
+		case "key":
+			byKey = true
+		}
+	}
+	selects = append(selects,
+		"COALESCE(SUM(request_count), 0) as requests",
+		"COALESCE(SUM(error_count), 0) as errors",
+		"COALESCE(SUM(tokens_used), 0) as tokens",
+		"COALESCE(SUM(cost), 0) as cost",
+		"COALESCE(SUM(total_latency_ms), 0) as total_latency_ms",
+	)
+	orders = append(orders, "cost DESC")
+
+	query := s.filtered(ctx, opts).Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", "))
+	}
+
+	var scanned []struct {
+		DayKey         string
+		Provider       string
+		Model          string
+		APIKeyID       uint
+		Requests       int64
+		Errors         int64
+		Tokens         int64
+		Cost           float64
+		TotalLatencyMs int64
+	}
+	if err := query.Order(strings.Join(orders, ", ")).Scan(&scanned).Error; err != nil {
+		return nil, UsageTotals{}, fmt.Errorf("analytics: query failed: %w", err)
+	}
+
+	rows := make([]UsageRow, 0, len(scanned))
+	var totals UsageTotals
+	var totalLatency int64
+	for _, r := range scanned {
+		row := UsageRow{
+			DayKey:       r.DayKey,
+			Provider:     r.Provider,
+			Model:        r.Model,
+			Requests:     r.Requests,
+			Errors:       r.Errors,
+			Tokens:       r.Tokens,
+			Cost:         r.Cost,
+			AvgLatencyMs: ratio(float64(r.TotalLatencyMs), r.Requests),
+			ErrorRate:    ratio(float64(r.Errors), r.Requests),
+		}
+		if byKey {
+			row.Key = KeyPlatform
+			if r.APIKeyID != 0 {
+				keyID := r.APIKeyID
+				row.Key = KeyBYOK
+				row.APIKeyID = &keyID
+			}
+		}
+		rows = append(rows, row)
+		totals.Requests += r.Requests
+		totals.Errors += r.Errors
+		totals.Tokens += r.Tokens
+		totals.Cost += r.Cost
+		totalLatency += r.TotalLatencyMs
+	}
+	totals.AvgLatencyMs = ratio(float64(totalLatency), totals.Requests)
+	totals.ErrorRate = ratio(float64(totals.Errors), totals.Requests)
+	return rows, totals, nil
+}
+
+func (s *Service) filtered(ctx context.Context, opts QueryOpts) *gorm.DB {
+	query := s.db.WithContext(ctx).Model(&AIUsageDaily{}).Where("user_id = ?", opts.UserID)
+	if !opts.From.IsZero() {
+		query = query.Where("day_key >= ?", opts.From.UTC().Format(dayKeyLayout))
+	}
+	if !opts.To.IsZero() {
+		query = query.Where("day_key <= ?", opts.To.UTC().Format(dayKeyLayout))
+	}
+	if opts.Provider != "" {
+		query = query.Where("provider = ?", opts.Provider)
+	}
+	if opts.Model != "" {
+		query = query.Where("model = ?", opts.Model)
+	}
+	return query
+}
+
+// ExportCSV renders grouped usage rows as CSV.
+func (s *Service) ExportCSV(ctx context.Context, opts QueryOpts) ([]byte, error) {
+	rows, _, err := s.Query(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{
+		"day", "provider", "model", "requests", "errors", "error_rate",
+		"tokens", "cost", "avg_latency_ms", "key", "api_key_id",
+	}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("analytics: csv header write failed: %w", err)
+	}
+	for _, r := range rows {
+		keyID := ""
+		if r.APIKeyID != nil {
+			keyID = strconv.FormatUint(uint64(*r.APIKeyID), 10)
+		}
+		row := []string{
+			r.DayKey,
+			r.Provider,
+			r.Model,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.Errors, 10),
+			strconv.FormatFloat(r.ErrorRate, 'f', 4, 64),
+			strconv.FormatInt(r.Tokens, 10),
+			strconv.FormatFloat(r.Cost, 'f', 6, 64),
+			strconv.FormatFloat(r.AvgLatencyMs, 'f', 1, 64),
+			r.Key,
+			keyID,
+		}
+		if err := w.Write(row); err != nil {
+			return nil, fmt.Errorf("analytics: csv row write failed: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("analytics: csv flush failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func ratio(num float64, denom int64) float64 {
+	if denom <= 0 {
+		return 0
+	}
+	return num / float64(denom)
+}
@@ -0,0 +1,180 @@
+package analytics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AIRequest{}, &AIUsageDaily{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+func seedRequest(t *testing.T, db *gorm.DB, id string, userID uint, provider, model, status string, tokens int, cost float64, durationMs int64, at time.Time) {
+	t.Helper()
+	req := models.AIRequest{
+		RequestID:  id,
+		UserID:     userID,
+		Provider:   provider,
+		Model:      model,
+		Capability: "code_generation",
+		Status:     status,
+		TokensUsed: tokens,
+		Cost:       cost,
+		Duration:   durationMs,
+	}
+	req.CreatedAt = at
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Omit("User", "Project").Create(&req).Error; err != nil {
+		t.Fatalf("seed request %s: %v", id, err)
+	}
+}
+
+func TestRollupDayAndQueryGroupsByProviderModelDay(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	seedRequest(t, db, "r1", 1, "claude", "claude-sonnet", "completed", 100, 0.01, 200, day1)
+	seedRequest(t, db, "r2", 1, "claude", "claude-sonnet", "failed", 0, 0, 400, day1.Add(time.Hour))
+	seedRequest(t, db, "r3", 1, "gpt4", "gpt-4o", "completed", 300, 0.03, 600, day1)
+	seedRequest(t, db, "r4", 1, "claude", "claude-sonnet", "completed", 50, 0.005, 100, day2)
+	seedRequest(t, db, "r5", 2, "claude", "claude-sonnet", "completed", 999, 9.99, 999, day1)
+
+	for _, day := range []time.Time{day1, day2} {
+		if _, err := svc.RollupDay(ctx, day); err != nil {
+			t.Fatalf("RollupDay(%s): %v", day.Format(dayKeyLayout), err)
+		}
+	}
+	// Re-running a day must replace rather than double-count.
+	if _, err := svc.RollupDay(ctx, day1); err != nil {
+		t.Fatalf("RollupDay rerun: %v", err)
+	}
+
+	rows, totals, err := svc.Query(ctx, QueryOpts{UserID: 1, From: day1, To: day2, GroupBy: NormalizeGroupBy("provider")})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 provider rows, got %+v", rows)
+	}
+	var claude UsageRow
+	for _, row := range rows {
+		if row.Provider == "claude" {
+			claude = row
+		}
+	}
+	if claude.Requests != 3 || claude.Errors != 1 || claude.Tokens != 150 {
+		t.Fatalf("unexpected claude row: %+v", claude)
+	}
+	if claude.AvgLatencyMs != 700.0/3 {
+		t.Fatalf("avg latency = %v, want %v", claude.AvgLatencyMs, 700.0/3)
+	}
+	if totals.Requests != 4 || totals.Errors != 1 || totals.ErrorRate != 0.25 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+
+	daily, _, err := svc.Query(ctx, QueryOpts{UserID: 1, From: day1, To: day2, GroupBy: NormalizeGroupBy("day")})
+	if err != nil {
+		t.Fatalf("Query by day: %v", err)
+	}
+	if len(daily) != 2 || daily[0].DayKey != "2026-03-01" || daily[0].Requests != 3 {
+		t.Fatalf("unexpected daily rows: %+v", daily)
+	}
+}
+
+func TestQueryBreaksCostDownByKey(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	seedRequest(t, db, "r1", 1, "claude", "claude-sonnet", "completed", 100, 0.01, 200, day)
+	seedRequest(t, db, "r2", 1, "claude", "claude-sonnet", "completed", 100, 0.02, 200, day)
+	seedRequest(t, db, "r3", 1, "claude", "claude-sonnet", "completed", 100, 0.04, 200, day)
+	if err := db.Model(&models.AIRequest{}).Where("request_id IN ?", []string{"r2", "r3"}).Update("api_key_id", 7).Error; err != nil {
+		t.Fatalf("tag key: %v", err)
+	}
+	if _, err := svc.RollupDay(ctx, day); err != nil {
+		t.Fatalf("RollupDay: %v", err)
+	}
+
+	rows, totals, err := svc.Query(ctx, QueryOpts{UserID: 1, From: day, To: day, GroupBy: NormalizeGroupBy("key")})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 key rows, got %+v", rows)
+	}
+	byok, platform := rows[0], rows[1]
+	if byok.Key != KeyBYOK || byok.APIKeyID == nil || *byok.APIKeyID != 7 || byok.Requests != 2 || byok.Cost < 0.0599 || byok.Cost > 0.0601 {
+		t.Fatalf("unexpected byok row: %+v", byok)
+	}
+	if platform.Key != KeyPlatform || platform.APIKeyID != nil || platform.Requests != 1 {
+		t.Fatalf("unexpected platform row: %+v", platform)
+	}
+	if totals.Requests != 3 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+
+	// Without the key dimension the two keys collapse into one row
+	merged, _, err := svc.Query(ctx, QueryOpts{UserID: 1, From: day, To: day, GroupBy: NormalizeGroupBy("provider")})
+	if err != nil {
+		t.Fatalf("Query by provider: %v", err)
+	}
+	if len(merged) != 1 || merged[0].Requests != 3 || merged[0].Key != "" {
+		t.Fatalf("unexpected provider rows: %+v", merged)
+	}
+}
+
+func TestExportCSVIncludesHeaderAndRows(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	seedRequest(t, db, "r1", 1, "gemini", "gemini-2.5-pro", "completed", 42, 0.002, 120, day)
+	if _, err := svc.RollupDay(ctx, day); err != nil {
+		t.Fatalf("RollupDay: %v", err)
+	}
+
+	data, err := svc.ExportCSV(ctx, QueryOpts{UserID: 1, From: day, To: day})
+	if err != nil {
+		t.Fatalf("ExportCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header + 1 row, got %q", string(data))
+	}
+	if !strings.HasPrefix(lines[0], "day,provider,model,requests") {
+		t.Fatalf("unexpected header: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "2026-03-01,gemini,gemini-2.5-pro,1,0,") {
+		t.Fatalf("unexpected row: %q", lines[1])
+	}
+}
+
+func TestNormalizeGroupBy(t *testing.T) {
+	if got := strings.Join(NormalizeGroupBy(""), ","); got != "day,provider,model" {
+		t.Fatalf("default group_by = %q", got)
+	}
+	if got := strings.Join(NormalizeGroupBy("model, bogus ,DAY"), ","); got != "day,model" {
+		t.Fatalf("normalized group_by = %q", got)
+	}
+	if got := strings.Join(NormalizeGroupBy("key,provider"), ","); got != "provider,key" {
+		t.Fatalf("normalized group_by = %q", got)
+	}
+}
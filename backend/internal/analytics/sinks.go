@@ -0,0 +1,254 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/oauth2/google"
+)
+
+// Sink receives exported warehouse tables as parquet files. Writing the
+// same table partition again replaces it.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, batch Batch, parquet []byte) (string, error)
+}
+
+// objectKey lays partitions out Hive style so Athena, Spark and external
+// BigQuery tables can read the prefix as a partitioned table
+func objectKey(prefix string, batch Batch) string {
+	if batch.Partition == "" {
+		return path.Join(prefix, batch.Table, batch.Table+".parquet")
+	}
+	return path.Join(prefix, batch.Table, "day="+batch.Partition, "part-0.parquet")
+}
+
+// S3Sink writes parquet files to an S3 compatible bucket
+type S3Sink struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Sink creates a sink using the default AWS credential chain. An
+// endpoint points it at an S3 compatible store such as R2 or MinIO.
+func NewS3Sink(ctx context.Context, bucket, prefix, region, endpoint string) (*S3Sink, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("analytics: S3 bucket is required")
+	}
+	opts := []func(*config.LoadOptions) error{}
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Sink{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+func (s *S3Sink) Name() string { return "s3" }
+
+func (s *S3Sink) Write(ctx context.Context, batch Batch, parquet []byte) (string, error) {
+	key := objectKey(s.prefix, batch)
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(parquet),
+		ContentLength: aws.Int64(int64(len(parquet))),
+		ContentType:   aws.String("application/vnd.apache.parquet"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("analytics: put s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return "s3://" + s.bucket + "/" + key, nil
+}
+
+// BigQuerySink loads parquet files into a BigQuery dataset. Daily tables
+// are partitioned by day and each load replaces its partition; the cohort
+// table is replaced whole. Tables are created on first load.
+type BigQuerySink struct {
+	project  string
+	dataset  string
+	endpoint string
+	client   *http.Client
+	poll     time.Duration
+}
+
+// NewBigQuerySink creates a sink using Google application default
+// credentials
+func NewBigQuerySink(ctx context.Context, project, dataset string) (*BigQuerySink, error) {
+	if project == "" || dataset == "" {
+		return nil, fmt.Errorf("analytics: BigQuery project and dataset are required")
+	}
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/bigquery")
+	if err != nil {
+		return nil, fmt.Errorf("analytics: BigQuery credentials: %w", err)
+	}
+	client.Timeout = 2 * time.Minute
+	endpoint := os.Getenv("BIGQUERY_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://bigquery.googleapis.com"
+	}
+	return &BigQuerySink{
+		project:  project,
+		dataset:  dataset,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   client,
+		poll:     2 * time.Second,
+	}, nil
+}
+
+func (s *BigQuerySink) Name() string { return "bigquery" }
+
+type bigQueryJob struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Status struct {
+		State       string `json:"state"`
+		ErrorResult *struct {
+			Message string `json:"message"`
+		} `json:"errorResult"`
+	} `json:"status"`
+}
+
+func (s *BigQuerySink) Write(ctx context.Context, batch Batch, parquet []byte) (string, error) {
+	table := batch.Table
+	load := map[string]any{
+		"destinationTable": map[string]string{
+			"projectId": s.project,
+			"datasetId": s.dataset,
+			"tableId":   table,
+		},
+		"sourceFormat":      "PARQUET",
+		"createDisposition": "CREATE_IF_NEEDED",
+		"writeDisposition":  "WRITE_TRUNCATE",
+	}
+	location := s.project + "." + s.dataset + "." + table
+	if batch.Partition != "" {
+		decorator := table + "$" + strings.ReplaceAll(batch.Partition, "-", "")
+		load["destinationTable"].(map[string]string)["tableId"] = decorator
+		load["timePartitioning"] = map[string]string{"type": "DAY", "field": batch.Columns[0].Name}
+		location = s.project + "." + s.dataset + "." + decorator
+	}
+	metadata, err := json.Marshal(map[string]any{"configuration": map[string]any{"load": load}})
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return "", err
+	}
+	part.Write(metadata)
+	part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return "", err
+	}
+	part.Write(parquet)
+	mw.Close()
+
+	url := fmt.Sprintf("%s/upload/bigquery/v2/projects/%s/jobs?uploadType=multipart", s.endpoint, s.project)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	var job bigQueryJob
+	if err := s.do(req, &job); err != nil {
+		return "", err
+	}
+
+	for job.Status.State != "DONE" {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("analytics: BigQuery load %s: %w", job.JobReference.JobID, ctx.Err())
+		case <-time.After(s.poll):
+		}
+		url := fmt.Sprintf("%s/bigquery/v2/projects/%s/jobs/%s?location=%s",
+			s.endpoint, s.project, job.JobReference.JobID, job.JobReference.Location)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		if err := s.do(req, &job); err != nil {
+			return "", err
+		}
+	}
+	if job.Status.ErrorResult != nil {
+		return "", fmt.Errorf("analytics: BigQuery load %s failed: %s", job.JobReference.JobID, job.Status.ErrorResult.Message)
+	}
+	return "bigquery://" + location, nil
+}
+
+func (s *BigQuerySink) do(req *http.Request, out any) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("analytics: BigQuery request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("analytics: BigQuery response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(body))
+		if len(message) > 300 {
+			message = message[:300]
+		}
+		return fmt.Errorf("analytics: BigQuery returned %d: %s", resp.StatusCode, message)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// SinksFromEnv builds the export sinks configured in the environment:
+// ANALYTICS_EXPORT_S3_BUCKET for parquet files on S3 and
+// ANALYTICS_BIGQUERY_PROJECT with ANALYTICS_BIGQUERY_DATASET for BigQuery.
+func SinksFromEnv(ctx context.Context) ([]Sink, []error) {
+	var sinks []Sink
+	var errs []error
+	if bucket := strings.TrimSpace(os.Getenv("ANALYTICS_EXPORT_S3_BUCKET")); bucket != "" {
+		prefix := os.Getenv("ANALYTICS_EXPORT_S3_PREFIX")
+		if prefix == "" {
+			prefix = "apex-analytics"
+		}
+		sink, err := NewS3Sink(ctx, bucket, prefix, os.Getenv("ANALYTICS_EXPORT_S3_REGION"), os.Getenv("ANALYTICS_EXPORT_S3_ENDPOINT"))
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	if project := strings.TrimSpace(os.Getenv("ANALYTICS_BIGQUERY_PROJECT")); project != "" {
+		sink, err := NewBigQuerySink(ctx, project, strings.TrimSpace(os.Getenv("ANALYTICS_BIGQUERY_DATASET")))
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks, errs
+}
@@ -0,0 +1,548 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"apex-build/internal/payments"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ActivationWindowDays is how long after signup a first build still
+	// counts as activation. Daily metrics are recomputed over this window.
+	ActivationWindowDays = 7
+
+	// RetentionWeeks is how many weekly cohorts, and how many weeks after
+	// signup, the warehouse tracks.
+	RetentionWeeks = 12
+
+	// DefaultWarehouseHour is the UTC hour the nightly warehouse job runs.
+	DefaultWarehouseHour = 2
+
+	// MaxTrendDays bounds a single trend query.
+	MaxTrendDays = 3 * 366
+
+	warehouseTimeout = 30 * time.Minute
+)
+
+// Warehouse export tables
+const (
+	TablePlatformDaily    = "platform_daily_metrics"
+	TablePlanDaily        = "plan_daily_metrics"
+	TableRetentionCohorts = "retention_cohorts"
+)
+
+// Warehouse export statuses
+const (
+	ExportSucceeded = "succeeded"
+	ExportFailed    = "failed"
+)
+
+// paidPlans are the subscription types that count as paying users
+var paidPlans = []string{"builder", "pro", "team", "enterprise"}
+
+// Warehouse builds platform-wide daily metrics and retention cohorts and
+// exports them to the configured sinks.
+type Warehouse struct {
+	db    *gorm.DB
+	sinks []Sink
+	hour  int
+	now   func() time.Time
+}
+
+// NewWarehouse creates a warehouse over the given database. Without sinks
+// the nightly job only aggregates.
+func NewWarehouse(db *gorm.DB, sinks ...Sink) *Warehouse {
+	return &Warehouse{db: db, sinks: sinks, hour: DefaultWarehouseHour, now: time.Now}
+}
+
+// SetHour sets the UTC hour of the nightly run
+func (w *Warehouse) SetHour(hour int) {
+	if hour >= 0 && hour < 24 {
+		w.hour = hour
+	}
+}
+
+// Sinks returns the names of the configured export sinks
+func (w *Warehouse) Sinks() []string {
+	names := make([]string, 0, len(w.sinks))
+	for _, sink := range w.sinks {
+		names = append(names, sink.Name())
+	}
+	return names
+}
+
+// AutoMigrate creates the warehouse tables
+func (w *Warehouse) AutoMigrate() error {
+	return w.db.AutoMigrate(&PlatformDailyMetric{}, &PlanDailyMetric{}, &RetentionCohort{}, &WarehouseExport{})
+}
+
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func weekStart(t time.Time) time.Time {
+	day := utcDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// activeUsersSQL selects the users with AI requests or builds in [?, ?)
+const activeUsersSQL = "SELECT user_id FROM ai_requests WHERE created_at >= ? AND created_at < ? AND deleted_at IS NULL " +
+	"UNION SELECT user_id FROM completed_builds WHERE created_at >= ? AND created_at < ? AND deleted_at IS NULL"
+
+// AggregateDay recomputes the platform and per-plan metrics for the UTC
+// day containing day.
+func (w *Warehouse) AggregateDay(ctx context.Context, day time.Time) error {
+	start := utcDay(day)
+	end := start.AddDate(0, 0, 1)
+	dayKey := start.Format(dayKeyLayout)
+	db := w.db.WithContext(ctx)
+
+	metric := PlatformDailyMetric{DayKey: dayKey}
+	if err := db.Model(&models.User{}).Where("created_at >= ? AND created_at < ?", start, end).
+		Count(&metric.Signups).Error; err != nil {
+		return fmt.Errorf("analytics: signups for %s: %w", dayKey, err)
+	}
+	if err := db.Raw("SELECT COUNT(*) FROM users u WHERE u.created_at >= ? AND u.created_at < ? AND u.deleted_at IS NULL AND EXISTS ("+
+		"SELECT 1 FROM completed_builds b WHERE b.user_id = u.id AND b.created_at < ?)",
+		start, end, end.AddDate(0, 0, ActivationWindowDays)).Scan(&metric.ActivatedUsers).Error; err != nil {
+		return fmt.Errorf("analytics: activation for %s: %w", dayKey, err)
+	}
+	metric.ActivationRate = ratio(float64(metric.ActivatedUsers), metric.Signups)
+	if err := db.Raw("SELECT COUNT(*) FROM ("+activeUsersSQL+") active", start, end, start, end).
+		Scan(&metric.ActiveUsers).Error; err != nil {
+		return fmt.Errorf("analytics: active users for %s: %w", dayKey, err)
+	}
+	if err := db.Model(&models.User{}).
+		Where("created_at < ? AND subscription_type IN ? AND subscription_status IN ?", end, paidPlans, []string{"active", "trialing", "past_due"}).
+		Count(&metric.PaidUsers).Error; err != nil {
+		return fmt.Errorf("analytics: paid users for %s: %w", dayKey, err)
+	}
+
+	// Per-plan rows use the user's current plan
+	plans := map[string]*PlanDailyMetric{}
+	planRow := func(plan string) *PlanDailyMetric {
+		if plan == "" {
+			plan = "free"
+		}
+		row, ok := plans[plan]
+		if !ok {
+			row = &PlanDailyMetric{DayKey: dayKey, Plan: plan}
+			plans[plan] = row
+		}
+		return row
+	}
+
+	var builds []struct {
+		Plan     string
+		Builds   int64
+		Failed   int64
+		Builders int64
+	}
+	if err := db.Table("completed_builds b").
+		Select("COALESCE(u.subscription_type, 'free') as plan, COUNT(*) as builds, "+
+			"COALESCE(SUM(CASE WHEN b.status = 'failed' THEN 1 ELSE 0 END), 0) as failed, "+
+			"COUNT(DISTINCT b.user_id) as builders").
+		Joins("LEFT JOIN users u ON u.id = b.user_id").
+		Where("b.created_at >= ? AND b.created_at < ? AND b.deleted_at IS NULL", start, end).
+		Group("COALESCE(u.subscription_type, 'free')").
+		Scan(&builds).Error; err != nil {
+		return fmt.Errorf("analytics: builds for %s: %w", dayKey, err)
+	}
+	for _, b := range builds {
+		metric.Builds += b.Builds
+		metric.BuildsFailed += b.Failed
+		row := planRow(b.Plan)
+		row.Builds += b.Builds
+		row.Builders += b.Builders
+	}
+
+	var usage []struct {
+		Plan     string
+		Requests int64
+		Cost     float64
+	}
+	if err := db.Table("ai_requests r").
+		Select("COALESCE(u.subscription_type, 'free') as plan, COUNT(*) as requests, COALESCE(SUM(r.cost), 0) as cost").
+		Joins("LEFT JOIN users u ON u.id = r.user_id").
+		Where("r.created_at >= ? AND r.created_at < ? AND r.deleted_at IS NULL", start, end).
+		Group("COALESCE(u.subscription_type, 'free')").
+		Scan(&usage).Error; err != nil {
+		return fmt.Errorf("analytics: AI usage for %s: %w", dayKey, err)
+	}
+	for _, u := range usage {
+		metric.AIRequests += u.Requests
+		metric.AICostUSD += u.Cost
+		row := planRow(u.Plan)
+		row.AIRequests += u.Requests
+		row.AICostUSD += u.Cost
+	}
+
+	var active []struct {
+		Plan  string
+		Users int64
+	}
+	if err := db.Raw("SELECT COALESCE(u.subscription_type, 'free') as plan, COUNT(*) as users FROM ("+activeUsersSQL+") active "+
+		"LEFT JOIN users u ON u.id = active.user_id GROUP BY COALESCE(u.subscription_type, 'free')",
+		start, end, start, end).Scan(&active).Error; err != nil {
+		return fmt.Errorf("analytics: active users by plan for %s: %w", dayKey, err)
+	}
+	for _, a := range active {
+		planRow(a.Plan).ActiveUsers += a.Users
+	}
+
+	revenue, err := w.revenue(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("analytics: revenue for %s: %w", dayKey, err)
+	}
+	for plan, amount := range revenue {
+		metric.RevenueUSD += amount
+		planRow(plan).RevenueUSD += amount
+	}
+	metric.GrossMarginUSD = metric.RevenueUSD - metric.AICostUSD
+
+	rows := make([]PlanDailyMetric, 0, len(plans))
+	for _, row := range plans {
+		rows = append(rows, *row)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day_key = ?", dayKey).Delete(&PlatformDailyMetric{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("day_key = ?", dayKey).Delete(&PlanDailyMetric{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&metric).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 100).Error
+	})
+	if err != nil {
+		return fmt.Errorf("analytics: write metrics for %s: %w", dayKey, err)
+	}
+	return nil
+}
+
+// revenue estimates the day's revenue by plan from the credit ledger:
+// credit purchases at face value and subscription renewals at the plan's
+// monthly list price. Stripe remains the source of truth for billing.
+func (w *Warehouse) revenue(ctx context.Context, start, end time.Time) (map[string]float64, error) {
+	var entries []struct {
+		EntryType string
+		PlanType  string
+		Plan      string
+		Count     int64
+		Amount    float64
+	}
+	err := w.db.WithContext(ctx).Table("credit_ledger_entries e").
+		Select("e.entry_type, COALESCE(e.plan_type, '') as plan_type, COALESCE(u.subscription_type, 'free') as plan, "+
+			"COUNT(*) as count, COALESCE(SUM(e.amount_usd), 0) as amount").
+		Joins("LEFT JOIN users u ON u.id = e.user_id").
+		Where("e.created_at >= ? AND e.created_at < ? AND e.entry_type IN ?", start, end, []string{"credit_purchase", "monthly_allocation"}).
+		Group("e.entry_type, COALESCE(e.plan_type, ''), COALESCE(u.subscription_type, 'free')").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	revenue := map[string]float64{}
+	for _, e := range entries {
+		switch e.EntryType {
+		case "credit_purchase":
+			revenue[e.Plan] += e.Amount
+		case "monthly_allocation":
+			plan := e.PlanType
+			if plan == "" {
+				plan = e.Plan
+			}
+			if p := payments.GetPlanByType(payments.PlanType(plan)); p != nil {
+				revenue[plan] += float64(p.MonthlyPriceCents*e.Count) / 100
+			}
+		}
+	}
+	return revenue, nil
+}
+
+// AggregateCohorts recomputes retention for the weekly signup cohorts of
+// the last RetentionWeeks weeks, up to the week containing now.
+func (w *Warehouse) AggregateCohorts(ctx context.Context, now time.Time) error {
+	db := w.db.WithContext(ctx)
+	current := weekStart(now)
+	for back := RetentionWeeks; back >= 0; back-- {
+		cohort := current.AddDate(0, 0, -7*back)
+		cohortEnd := cohort.AddDate(0, 0, 7)
+		cohortKey := cohort.Format(dayKeyLayout)
+
+		var size int64
+		if err := db.Model(&models.User{}).Where("created_at >= ? AND created_at < ?", cohort, cohortEnd).
+			Count(&size).Error; err != nil {
+			return fmt.Errorf("analytics: cohort %s: %w", cohortKey, err)
+		}
+
+		rows := make([]RetentionCohort, 0, back+1)
+		for offset := 0; offset <= back; offset++ {
+			from := cohort.AddDate(0, 0, 7*offset)
+			to := from.AddDate(0, 0, 7)
+			row := RetentionCohort{CohortWeek: cohortKey, WeekOffset: offset, CohortSize: size}
+			if size > 0 {
+				if err := db.Raw("SELECT COUNT(*) FROM ("+activeUsersSQL+") active WHERE active.user_id IN ("+
+					"SELECT id FROM users WHERE created_at >= ? AND created_at < ? AND deleted_at IS NULL)",
+					from, to, from, to, cohort, cohortEnd).Scan(&row.RetainedUsers).Error; err != nil {
+					return fmt.Errorf("analytics: cohort %s week %d: %w", cohortKey, offset, err)
+				}
+			}
+			row.RetentionRate = ratio(float64(row.RetainedUsers), row.CohortSize)
+			rows = append(rows, row)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("cohort_week = ?", cohortKey).Delete(&RetentionCohort{}).Error; err != nil {
+				return err
+			}
+			return tx.CreateInBatches(rows, 100).Error
+		})
+		if err != nil {
+			return fmt.Errorf("analytics: write cohort %s: %w", cohortKey, err)
+		}
+	}
+	return nil
+}
+
+// Rebuild recomputes daily metrics for every day in [from, to] and the
+// current retention cohorts
+func (w *Warehouse) Rebuild(ctx context.Context, from, to time.Time) (int, error) {
+	days := 0
+	for day := utcDay(from); !day.After(utcDay(to)); day = day.AddDate(0, 0, 1) {
+		if err := w.AggregateDay(ctx, day); err != nil {
+			return days, err
+		}
+		days++
+	}
+	return days, w.AggregateCohorts(ctx, w.now())
+}
+
+// RunNightly aggregates the activation window ending yesterday and the
+// retention cohorts, then exports those partitions and the cohort table
+// to every sink. Export failures are recorded and do not stop the run.
+func (w *Warehouse) RunNightly(ctx context.Context) error {
+	yesterday := utcDay(w.now()).AddDate(0, 0, -1)
+	from := yesterday.AddDate(0, 0, -ActivationWindowDays)
+	if _, err := w.Rebuild(ctx, from, yesterday); err != nil {
+		return err
+	}
+	w.Export(ctx, from, yesterday)
+	return nil
+}
+
+// Export writes the daily partitions in [from, to] and a snapshot of the
+// retention cohorts to every sink
+func (w *Warehouse) Export(ctx context.Context, from, to time.Time) []WarehouseExport {
+	var results []WarehouseExport
+	if len(w.sinks) == 0 {
+		return results
+	}
+	for day := utcDay(from); !day.After(utcDay(to)); day = day.AddDate(0, 0, 1) {
+		dayKey := day.Format(dayKeyLayout)
+		for _, table := range []string{TablePlatformDaily, TablePlanDaily} {
+			batch, err := w.tableBatch(ctx, table, dayKey)
+			results = append(results, w.writeAll(ctx, batch, err)...)
+		}
+	}
+	batch, err := w.tableBatch(ctx, TableRetentionCohorts, "")
+	results = append(results, w.writeAll(ctx, batch, err)...)
+	return results
+}
+
+func (w *Warehouse) writeAll(ctx context.Context, batch Batch, buildErr error) []WarehouseExport {
+	results := make([]WarehouseExport, 0, len(w.sinks))
+	var data []byte
+	if buildErr == nil {
+		data, buildErr = EncodeParquet(batch.Columns, batch.Rows)
+	}
+	for _, sink := range w.sinks {
+		started := time.Now()
+		record := WarehouseExport{
+			Sink:      sink.Name(),
+			Table:     batch.Table,
+			Partition: batch.Partition,
+			Rows:      len(batch.Rows),
+			Bytes:     len(data),
+			Status:    ExportSucceeded,
+		}
+		err := buildErr
+		if err == nil {
+			record.Location, err = sink.Write(ctx, batch, data)
+		}
+		if err != nil {
+			record.Status = ExportFailed
+			record.Error = err.Error()
+			log.Printf("[warehouse] export %s/%s %s failed: %v", sink.Name(), batch.Table, batch.Partition, err)
+		}
+		record.DurationMs = time.Since(started).Milliseconds()
+		if err := w.db.WithContext(ctx).Create(&record).Error; err != nil {
+			log.Printf("[warehouse] failed to record export: %v", err)
+		}
+		results = append(results, record)
+	}
+	return results
+}
+
+// Batch is one table partition ready to export. Partition is a day key,
+// or empty for a full snapshot of the table.
+type Batch struct {
+	Table     string
+	Partition string
+	Columns   []Column
+	Rows      [][]any
+}
+
+var platformDailyColumns = []Column{
+	{"day", ColumnDate}, {"signups", ColumnInt64}, {"activated_users", ColumnInt64},
+	{"activation_rate", ColumnFloat64}, {"active_users", ColumnInt64}, {"paid_users", ColumnInt64},
+	{"builds", ColumnInt64}, {"builds_failed", ColumnInt64}, {"ai_requests", ColumnInt64},
+	{"ai_cost_usd", ColumnFloat64}, {"revenue_usd", ColumnFloat64}, {"gross_margin_usd", ColumnFloat64},
+}
+
+var planDailyColumns = []Column{
+	{"day", ColumnDate}, {"plan", ColumnString}, {"builds", ColumnInt64}, {"builders", ColumnInt64},
+	{"active_users", ColumnInt64}, {"ai_requests", ColumnInt64}, {"ai_cost_usd", ColumnFloat64},
+	{"revenue_usd", ColumnFloat64},
+}
+
+var retentionColumns = []Column{
+	{"cohort_week", ColumnDate}, {"week_offset", ColumnInt64}, {"cohort_size", ColumnInt64},
+	{"retained_users", ColumnInt64}, {"retention_rate", ColumnFloat64},
+}
+
+func (w *Warehouse) tableBatch(ctx context.Context, table, dayKey string) (Batch, error) {
+	batch := Batch{Table: table, Partition: dayKey}
+	db := w.db.WithContext(ctx)
+	switch table {
+	case TablePlatformDaily:
+		batch.Columns = platformDailyColumns
+		var rows []PlatformDailyMetric
+		if err := db.Where("day_key = ?", dayKey).Find(&rows).Error; err != nil {
+			return batch, err
+		}
+		for _, m := range rows {
+			batch.Rows = append(batch.Rows, []any{m.DayKey, m.Signups, m.ActivatedUsers, m.ActivationRate,
+				m.ActiveUsers, m.PaidUsers, m.Builds, m.BuildsFailed, m.AIRequests, m.AICostUSD, m.RevenueUSD, m.GrossMarginUSD})
+		}
+	case TablePlanDaily:
+		batch.Columns = planDailyColumns
+		var rows []PlanDailyMetric
+		if err := db.Where("day_key = ?", dayKey).Order("plan").Find(&rows).Error; err != nil {
+			return batch, err
+		}
+		for _, m := range rows {
+			batch.Rows = append(batch.Rows, []any{m.DayKey, m.Plan, m.Builds, m.Builders, m.ActiveUsers,
+				m.AIRequests, m.AICostUSD, m.RevenueUSD})
+		}
+	case TableRetentionCohorts:
+		batch.Columns = retentionColumns
+		var rows []RetentionCohort
+		if err := db.Order("cohort_week, week_offset").Find(&rows).Error; err != nil {
+			return batch, err
+		}
+		for _, m := range rows {
+			batch.Rows = append(batch.Rows, []any{m.CohortWeek, int64(m.WeekOffset), m.CohortSize, m.RetainedUsers, m.RetentionRate})
+		}
+	default:
+		return batch, fmt.Errorf("analytics: unknown warehouse table %q", table)
+	}
+	return batch, nil
+}
+
+// Start launches the nightly job at the configured UTC hour
+func (w *Warehouse) Start(ctx context.Context) {
+	if w == nil || w.db == nil {
+		return
+	}
+	go func() {
+		log.Printf("[warehouse] nightly job scheduled hour=%02d:00 UTC sinks=%v", w.hour, w.Sinks())
+		for {
+			now := w.now().UTC()
+			next := time.Date(now.Year(), now.Month(), now.Day(), w.hour, 0, 0, 0, time.UTC)
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				log.Printf("[warehouse] nightly job stopped")
+				return
+			case <-timer.C:
+			}
+			runCtx, cancel := context.WithTimeout(ctx, warehouseTimeout)
+			if err := w.RunNightly(runCtx); err != nil {
+				log.Printf("[warehouse] nightly run failed: %v", err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// Trends returns the platform daily metrics in the range, oldest first
+func (w *Warehouse) Trends(ctx context.Context, q TrendQuery) ([]PlatformDailyMetric, error) {
+	var rows []PlatformDailyMetric
+	err := w.db.WithContext(ctx).
+		Where("day_key >= ? AND day_key <= ?", q.From.UTC().Format(dayKeyLayout), q.To.UTC().Format(dayKeyLayout)).
+		Order("day_key ASC").Find(&rows).Error
+	return rows, err
+}
+
+// PlanTrends returns the per-plan daily metrics in the range
+func (w *Warehouse) PlanTrends(ctx context.Context, q TrendQuery) ([]PlanDailyMetric, error) {
+	var rows []PlanDailyMetric
+	err := w.db.WithContext(ctx).
+		Where("day_key >= ? AND day_key <= ?", q.From.UTC().Format(dayKeyLayout), q.To.UTC().Format(dayKeyLayout)).
+		Order("day_key ASC, plan ASC").Find(&rows).Error
+	return rows, err
+}
+
+// Cohorts returns the retention cohorts whose signup week starts in the
+// range
+func (w *Warehouse) Cohorts(ctx context.Context, q TrendQuery) ([]RetentionCohort, error) {
+	var rows []RetentionCohort
+	err := w.db.WithContext(ctx).
+		Where("cohort_week >= ? AND cohort_week <= ?", weekStart(q.From).Format(dayKeyLayout), q.To.UTC().Format(dayKeyLayout)).
+		Order("cohort_week ASC, week_offset ASC").Find(&rows).Error
+	return rows, err
+}
+
+// Exports returns the most recent export records
+func (w *Warehouse) Exports(ctx context.Context, limit int) ([]WarehouseExport, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var rows []WarehouseExport
+	err := w.db.WithContext(ctx).Order("id DESC").Limit(limit).Find(&rows).Error
+	return rows, err
+}
+
+// SummarizeTrends totals daily metrics. Active and paid users are daily
+// gauges and are left out.
+func SummarizeTrends(rows []PlatformDailyMetric) TrendTotals {
+	var totals TrendTotals
+	for _, m := range rows {
+		totals.Signups += m.Signups
+		totals.ActivatedUsers += m.ActivatedUsers
+		totals.Builds += m.Builds
+		totals.BuildsFailed += m.BuildsFailed
+		totals.AIRequests += m.AIRequests
+		totals.AICostUSD += m.AICostUSD
+		totals.RevenueUSD += m.RevenueUSD
+	}
+	totals.ActivationRate = ratio(float64(totals.ActivatedUsers), totals.Signups)
+	totals.GrossMarginUSD = totals.RevenueUSD - totals.AICostUSD
+	return totals
+}
@@ -0,0 +1,326 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"apex-build/internal/payments"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupWarehouseDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.AIRequest{}, &models.CompletedBuild{}, &models.CreditLedgerEntry{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := NewWarehouse(db).AutoMigrate(); err != nil {
+		t.Fatalf("failed to migrate warehouse: %v", err)
+	}
+	return db
+}
+
+func seedUser(t *testing.T, db *gorm.DB, name, plan, status string, at time.Time) uint {
+	t.Helper()
+	user := models.User{
+		Username:           name,
+		Email:              name + "@example.com",
+		SubscriptionType:   plan,
+		SubscriptionStatus: status,
+	}
+	user.CreatedAt = at
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user %s: %v", name, err)
+	}
+	return user.ID
+}
+
+func seedBuild(t *testing.T, db *gorm.DB, id string, userID uint, status string, at time.Time) {
+	t.Helper()
+	build := models.CompletedBuild{BuildID: id, UserID: userID, Status: status}
+	build.CreatedAt = at
+	if err := db.Create(&build).Error; err != nil {
+		t.Fatalf("seed build %s: %v", id, err)
+	}
+}
+
+func TestAggregateDayComputesSignupsActivationCostAndRevenue(t *testing.T) {
+	db := setupWarehouseDB(t)
+	w := NewWarehouse(db)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	alice := seedUser(t, db, "alice", "pro", "active", day.Add(2*time.Hour))
+	bob := seedUser(t, db, "bob", "free", "inactive", day.Add(5*time.Hour))
+	seedUser(t, db, "carol", "free", "inactive", day.Add(9*time.Hour))
+	seedUser(t, db, "dave", "free", "inactive", day.AddDate(0, 0, -3))
+
+	// alice builds on signup day, bob only after the activation window
+	seedBuild(t, db, "b1", alice, "completed", day.Add(3*time.Hour))
+	seedBuild(t, db, "b2", alice, "failed", day.Add(4*time.Hour))
+	seedBuild(t, db, "b3", bob, "completed", day.AddDate(0, 0, 10))
+
+	seedRequest(t, db, "r1", alice, "claude", "sonnet", "completed", 100, 1.25, 100, day.Add(3*time.Hour))
+	seedRequest(t, db, "r2", bob, "gpt4", "gpt-4o", "completed", 100, 0.25, 100, day.Add(6*time.Hour))
+
+	db.Create(&models.CreditLedgerEntry{UserID: alice, AmountUSD: 20, EntryType: "credit_purchase", CreatedAt: day.Add(7 * time.Hour)})
+	db.Create(&models.CreditLedgerEntry{UserID: alice, AmountUSD: 10, EntryType: "monthly_allocation", PlanType: "pro", CreatedAt: day.Add(8 * time.Hour)})
+
+	if err := w.AggregateDay(ctx, day); err != nil {
+		t.Fatalf("AggregateDay: %v", err)
+	}
+	rows, err := w.Trends(ctx, TrendQuery{From: day, To: day})
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("Trends = %v, %v", rows, err)
+	}
+	m := rows[0]
+	if m.Signups != 3 || m.ActivatedUsers != 1 || math.Abs(m.ActivationRate-1.0/3) > 1e-9 {
+		t.Fatalf("signups/activation = %d/%d/%f", m.Signups, m.ActivatedUsers, m.ActivationRate)
+	}
+	if m.ActiveUsers != 2 || m.PaidUsers != 1 || m.Builds != 2 || m.BuildsFailed != 1 || m.AIRequests != 2 {
+		t.Fatalf("unexpected activity metrics %+v", m)
+	}
+	if math.Abs(m.AICostUSD-1.5) > 1e-9 {
+		t.Fatalf("ai cost = %f", m.AICostUSD)
+	}
+	// $20 of credits plus one month of the pro list price
+	proRevenue := 20 + float64(proMonthlyCents(t))/100
+	if math.Abs(m.RevenueUSD-proRevenue) > 1e-9 || math.Abs(m.GrossMarginUSD-(proRevenue-1.5)) > 1e-9 {
+		t.Fatalf("revenue/margin = %f/%f, want %f", m.RevenueUSD, m.GrossMarginUSD, proRevenue)
+	}
+
+	plans, err := w.PlanTrends(ctx, TrendQuery{From: day, To: day})
+	if err != nil || len(plans) != 2 {
+		t.Fatalf("PlanTrends = %v, %v", plans, err)
+	}
+	if plans[0].Plan != "free" || plans[0].Builds != 0 || plans[0].ActiveUsers != 1 || plans[0].AIRequests != 1 {
+		t.Fatalf("free plan row = %+v", plans[0])
+	}
+	if plans[1].Plan != "pro" || plans[1].Builds != 2 || plans[1].Builders != 1 || math.Abs(plans[1].RevenueUSD-proRevenue) > 1e-9 {
+		t.Fatalf("pro plan row = %+v", plans[1])
+	}
+
+	// Re-aggregating replaces the day instead of duplicating it
+	if err := w.AggregateDay(ctx, day); err != nil {
+		t.Fatalf("AggregateDay again: %v", err)
+	}
+	var count int64
+	db.Model(&PlatformDailyMetric{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 platform row, got %d", count)
+	}
+}
+
+func proMonthlyCents(t *testing.T) int64 {
+	t.Helper()
+	plan := payments.GetPlanByType(payments.PlanPro)
+	if plan == nil || plan.MonthlyPriceCents <= 0 {
+		t.Fatalf("pro plan has no monthly price")
+	}
+	return plan.MonthlyPriceCents
+}
+
+func TestAggregateCohortsCountsWeeklyRetention(t *testing.T) {
+	db := setupWarehouseDB(t)
+	// A Wednesday, so the current cohort week started on Monday 2026-03-02
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	w := NewWarehouse(db)
+	w.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cohort := time.Date(2026, 2, 16, 0, 0, 0, 0, time.UTC)
+	alice := seedUser(t, db, "alice", "free", "inactive", cohort.Add(time.Hour))
+	bob := seedUser(t, db, "bob", "free", "inactive", cohort.AddDate(0, 0, 3))
+	seedBuild(t, db, "b1", alice, "completed", cohort.Add(2*time.Hour))
+	seedBuild(t, db, "b2", bob, "completed", cohort.AddDate(0, 0, 4))
+	seedRequest(t, db, "r1", alice, "claude", "sonnet", "completed", 1, 0.01, 1, cohort.AddDate(0, 0, 15))
+
+	if err := w.AggregateCohorts(ctx, now); err != nil {
+		t.Fatalf("AggregateCohorts: %v", err)
+	}
+	rows, err := w.Cohorts(ctx, TrendQuery{From: cohort, To: cohort})
+	if err != nil {
+		t.Fatalf("Cohorts: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected weeks 0-2 for the cohort, got %+v", rows)
+	}
+	want := []int64{2, 0, 1}
+	for i, row := range rows {
+		if row.CohortWeek != "2026-02-16" || row.WeekOffset != i || row.CohortSize != 2 || row.RetainedUsers != want[i] {
+			t.Fatalf("week %d = %+v", i, row)
+		}
+	}
+	if rows[2].RetentionRate != 0.5 {
+		t.Fatalf("retention rate = %f", rows[2].RetentionRate)
+	}
+}
+
+type captureSink struct {
+	batches map[string]Batch
+	files   map[string][]byte
+	fail    bool
+}
+
+func (s *captureSink) Name() string { return "capture" }
+
+func (s *captureSink) Write(_ context.Context, batch Batch, data []byte) (string, error) {
+	if s.fail {
+		return "", fmt.Errorf("sink offline")
+	}
+	key := objectKey("test", batch)
+	s.batches[key] = batch
+	s.files[key] = data
+	return key, nil
+}
+
+func TestExportWritesParquetPartitionsAndRecordsRuns(t *testing.T) {
+	db := setupWarehouseDB(t)
+	sink := &captureSink{batches: map[string]Batch{}, files: map[string][]byte{}}
+	w := NewWarehouse(db, sink)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	seedUser(t, db, "alice", "free", "inactive", day.Add(time.Hour))
+	if _, err := w.Rebuild(ctx, day, day); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	results := w.Export(ctx, day, day)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 exports, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Status != ExportSucceeded {
+			t.Fatalf("export failed: %+v", r)
+		}
+	}
+
+	data := sink.files["test/platform_daily_metrics/day=2026-03-02/part-0.parquet"]
+	if data == nil {
+		t.Fatalf("missing platform partition, have %v", sink.files)
+	}
+	meta := readParquetFooter(t, data)
+	if meta[3] != int64(1) {
+		t.Fatalf("num_rows = %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != len(platformDailyColumns)+1 {
+		t.Fatalf("schema has %d elements", len(schema))
+	}
+	if name := string(schema[2].(map[int16]any)[4].([]byte)); name != "signups" {
+		t.Fatalf("second column = %q", name)
+	}
+
+	// The signups column chunk holds one plain int64
+	chunk := meta[4].([]any)[0].(map[int16]any)[1].([]any)[1].(map[int16]any)[3].(map[int16]any)
+	offset := chunk[9].(int64)
+	size := chunk[7].(int64)
+	page := data[offset : offset+size]
+	r := &thriftReader{buf: bytes.NewReader(page)}
+	r.readStruct()
+	values := page[len(page)-r.buf.Len():]
+	if len(values) != 8 || binary.LittleEndian.Uint64(values) != 1 {
+		t.Fatalf("signups values = %v", values)
+	}
+
+	var recorded int64
+	db.Model(&WarehouseExport{}).Where("status = ?", ExportSucceeded).Count(&recorded)
+	if recorded != 3 {
+		t.Fatalf("expected 3 recorded exports, got %d", recorded)
+	}
+
+	sink.fail = true
+	results = w.Export(ctx, day, day)
+	if results[0].Status != ExportFailed || results[0].Error == "" {
+		t.Fatalf("expected failed export, got %+v", results[0])
+	}
+}
+
+func TestEncodeParquetRejectsMismatchedValues(t *testing.T) {
+	if _, err := EncodeParquet([]Column{{"n", ColumnInt64}}, [][]any{{"x"}}); err == nil {
+		t.Fatal("expected an error for a string in an int64 column")
+	}
+	if _, err := EncodeParquet([]Column{{"d", ColumnDate}}, [][]any{{"03/02/2026"}}); err == nil {
+		t.Fatal("expected an error for a malformed day")
+	}
+}
+
+func readParquetFooter(t *testing.T, data []byte) map[int16]any {
+	t.Helper()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("missing parquet magic")
+	}
+	length := binary.LittleEndian.Uint32(data[len(data)-8:])
+	footer := data[len(data)-8-int(length) : len(data)-8]
+	r := &thriftReader{buf: bytes.NewReader(footer)}
+	meta := r.readStruct()
+	if r.buf.Len() != 0 {
+		t.Fatalf("%d trailing footer bytes", r.buf.Len())
+	}
+	return meta
+}
+
+// thriftReader decodes the compact protocol into maps keyed by field ID
+type thriftReader struct {
+	buf *bytes.Reader
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, _ := binary.ReadUvarint(r.buf)
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		v := r.varint()
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		b := make([]byte, r.varint())
+		r.buf.Read(b)
+		return b
+	case thriftList:
+		header, _ := r.buf.ReadByte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		items := make([]any, size)
+		for i := range items {
+			items[i] = r.value(header & 0x0f)
+		}
+		return items
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", typ))
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for {
+		header, err := r.buf.ReadByte()
+		if err != nil || header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v := r.varint()
+			id = int16(v>>1) ^ -int16(v&1)
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
@@ -356,6 +356,13 @@ func (s *Server) ServeAsset(c *gin.Context) {
 	// Remove leading slash from wildcard param
 	key = strings.TrimPrefix(key, "/")
 
+	// Project file blobs and in-progress upload chunks are only served
+	// through the authorized file API.
+	if strings.HasPrefix(key, "blobs/") || strings.HasPrefix(key, "uploads/") {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Asset not found"})
+		return
+	}
+
 	if s.storage == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Storage not available"})
 		return
@@ -0,0 +1,270 @@
+// APEX.BUILD Binary File Handlers
+// Images, fonts and other binary project files. Bytes live in the
+// content-addressed blob store; the files row keeps the hash and metadata.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	appmiddleware "apex-build/internal/middleware"
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBinaryFileSize = 25 * 1024 * 1024 // 25 MB per file
+
+// binaryFileContentType picks the Content-Type for a file: its stored mime
+// type, then its extension, then a generic fallback.
+func binaryFileContentType(file *models.File) string {
+	if file.MimeType != "" {
+		return file.MimeType
+	}
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(file.Path))); contentType != "" {
+		return contentType
+	}
+	if file.IsBinary {
+		return "application/octet-stream"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// fileRawURL is the download URL for a file's bytes.
+func fileRawURL(id uint) string {
+	return "/api/v1/files/" + strconv.FormatUint(uint64(id), 10) + "/raw"
+}
+
+// cleanUploadPath normalizes a client-supplied project path, rejecting ones
+// that resolve to the project root.
+func cleanUploadPath(filePath string) (string, bool) {
+	filePath = path.Clean("/" + strings.ReplaceAll(filePath, "\\", "/"))[1:]
+	return filePath, filePath != "" && filePath != "."
+}
+
+// UploadFile handles POST /api/v1/projects/:id/files/upload. It stores a
+// multipart "file" as a binary project file at the form's "path" (default:
+// the upload's file name). Replacing an existing file requires If-Match,
+// like PUT /files/:id.
+func (s *Server) UploadFile(c *gin.Context) {
+	projectID := c.Param("id")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	if s.storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage not available"})
+		return
+	}
+
+	var project models.Project
+	if err := s.db.DB.Where("id = ? AND owner_id = ?", projectID, uid).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBinaryFileSize+1024*1024)
+	upload, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided — use field name 'file' (max 25 MB)"})
+		return
+	}
+	defer upload.Close()
+
+	if header.Size > maxBinaryFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File too large: %.1f MB (max 25 MB); use POST /projects/:id/uploads for larger files", float64(header.Size)/1024/1024),
+		})
+		return
+	}
+
+	filePath := strings.TrimSpace(c.PostForm("path"))
+	if filePath == "" {
+		filePath = header.Filename
+	}
+	filePath, ok = cleanUploadPath(filePath)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file path"})
+		return
+	}
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filePath)))
+	if mimeType == "" {
+		if detected, err := detectMimeType(upload); err == nil {
+			mimeType = detected
+		}
+	}
+
+	var existing models.File
+	replacing := s.db.DB.Where("project_id = ? AND path = ?", project.ID, filePath).Limit(1).Find(&existing).Error == nil && existing.ID != 0
+	if replacing {
+		if existing.Type == "directory" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A directory already exists at that path"})
+			return
+		}
+		ifMatch := c.GetHeader("If-Match")
+		if ifMatch == "" {
+			c.JSON(http.StatusPreconditionRequired, gin.H{
+				"error": "If-Match header is required to replace an existing file; send its ETag or * to overwrite",
+				"code":  "PRECONDITION_REQUIRED",
+			})
+			return
+		}
+		if !appmiddleware.ETagMatches(ifMatch, fileETag(&existing)) {
+			s.respondFileConflict(c, &existing, nil)
+			return
+		}
+	}
+
+	if !s.allowStorageDelta(c, project.ID, header.Size-existing.Size) {
+		return
+	}
+
+	hash, size, err := storage.PutBlob(c.Request.Context(), s.storage, upload, maxBinaryFileSize, mimeType)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large (max 25 MB)"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	var current *models.File
+	if replacing {
+		current = &existing
+	}
+	file, ok := s.saveBinaryFile(c, uid, project.ID, filePath, mimeType, hash, size, current, existing.Version)
+	if !ok {
+		return
+	}
+	status := http.StatusCreated
+	if replacing {
+		status = http.StatusOK
+	}
+
+	c.Header("ETag", fileETag(&file))
+	c.JSON(status, gin.H{
+		"message": "File uploaded successfully",
+		"file":    file,
+		"raw_url": fileRawURL(file.ID),
+	})
+}
+
+// saveBinaryFile points a project file at a stored blob. With current nil it
+// creates the file; otherwise it replaces current, claiming expectVersion the
+// same way UpdateFile does so a concurrent save loses cleanly. On failure the
+// error response has already been written.
+func (s *Server) saveBinaryFile(c *gin.Context, uid, projectID uint, filePath, mimeType, hash string, size int64, current *models.File, expectVersion int) (models.File, bool) {
+	var file models.File
+	if current == nil {
+		file = models.File{
+			ProjectID:  projectID,
+			Path:       filePath,
+			Name:       path.Base(filePath),
+			Type:       "file",
+			MimeType:   mimeType,
+			Size:       size,
+			Hash:       hash,
+			IsBinary:   true,
+			Version:    1,
+			LastEditBy: uid,
+		}
+		if err := s.db.DB.Create(&file).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
+			return file, false
+		}
+		s.recordStorageChange(c.Request.Context(), uid, projectID, size)
+		return file, true
+	}
+
+	result := s.db.DB.Model(&models.File{}).
+		Where("id = ? AND version = ?", current.ID, expectVersion).
+		Updates(map[string]interface{}{
+			"content":      "",
+			"is_binary":    true,
+			"hash":         hash,
+			"size":         size,
+			"mime_type":    mimeType,
+			"last_edit_by": uid,
+			"version":      expectVersion + 1,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+		return file, false
+	}
+	if err := s.db.DB.First(&file, current.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load file"})
+		return file, false
+	}
+	if result.RowsAffected == 0 {
+		s.respondFileConflict(c, &file, nil)
+		return file, false
+	}
+	s.recordStorageChange(c.Request.Context(), uid, projectID, size-current.Size)
+	return file, true
+}
+
+// DownloadFile handles GET /api/v1/files/:id/raw. It streams a file's bytes
+// (text or binary) with its content type, honors If-None-Match, and serves
+// byte ranges.
+func (s *Server) DownloadFile(c *gin.Context) {
+	fileID := c.Param("id")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	var file models.File
+	if err := s.db.DB.Preload("Project").Where("id = ?", fileID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if file.Project.OwnerID != uid && !file.Project.IsPublic {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if file.Type == "directory" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Directories have no content"})
+		return
+	}
+
+	if appmiddleware.NotModified(c, fileETag(&file)) {
+		return
+	}
+
+	// User content is served from the API origin, so never let it run.
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": file.Name}))
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Type", binaryFileContentType(&file))
+
+	// http.ServeContent answers Range and If-Range requests with 206 / 416,
+	// so large media can be streamed and resumed.
+	if !file.IsBinary {
+		http.ServeContent(c.Writer, c.Request, file.Name, file.UpdatedAt, strings.NewReader(file.Content))
+		return
+	}
+
+	if s.storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage not available"})
+		return
+	}
+	if !storage.ValidBlobHash(file.Hash) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File content missing"})
+		return
+	}
+	content := storage.NewReadSeeker(c.Request.Context(), s.storage, storage.BlobKey(file.Hash), file.Size)
+	defer content.Close()
+	http.ServeContent(c.Writer, c.Request, file.Name, file.UpdatedAt, content)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func fileRequest(t *testing.T, handler gin.HandlerFunc, method string, fileID, userID uint, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(method, fmt.Sprintf("/api/v1/files/%d", fileID), strings.NewReader(body))
+	context.Request.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		context.Request.Header.Set(k, v)
+	}
+	context.Params = gin.Params{{Key: "id", Value: fmt.Sprint(fileID)}}
+	context.Set("user_id", userID)
+	handler(context)
+	return recorder
+}
+
+func TestGetFileHonorsIfNoneMatch(t *testing.T) {
+	server, userID, gormDB := newProjectAPITestServer(t, "pro")
+	project := models.Project{Name: "ETag", Language: "go", OwnerID: userID}
+	require.NoError(t, gormDB.Create(&project).Error)
+	file := models.File{ProjectID: project.ID, Path: "main.go", Name: "main.go", Type: "file", Content: "package main\n", Version: 1}
+	require.NoError(t, gormDB.Create(&file).Error)
+
+	first := fileRequest(t, server.GetFile, http.MethodGet, file.ID, userID, "", nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	again := fileRequest(t, server.GetFile, http.MethodGet, file.ID, userID, "", map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, again.Code)
+	require.Empty(t, again.Body.String())
+
+	// Another writer changes the content without bumping the version.
+	require.NoError(t, gormDB.Model(&file).Update("content", "package main\n\nfunc main() {}\n").Error)
+	changed := fileRequest(t, server.GetFile, http.MethodGet, file.ID, userID, "", map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, changed.Code)
+	require.NotEqual(t, etag, changed.Header().Get("ETag"))
+}
+
+func TestUpdateFileRequiresMatchingIfMatch(t *testing.T) {
+	server, userID, gormDB := newProjectAPITestServer(t, "pro")
+	project := models.Project{Name: "Conflicts", Language: "go", OwnerID: userID}
+	require.NoError(t, gormDB.Create(&project).Error)
+	file := models.File{ProjectID: project.ID, Path: "main.go", Name: "main.go", Type: "file", Content: "a\nb\n", Version: 1}
+	require.NoError(t, gormDB.Create(&file).Error)
+	etag := fileETag(&file)
+
+	missing := fileRequest(t, server.UpdateFile, http.MethodPut, file.ID, userID, `{"content":"a\nc\n"}`, nil)
+	require.Equal(t, http.StatusPreconditionRequired, missing.Code)
+
+	saved := fileRequest(t, server.UpdateFile, http.MethodPut, file.ID, userID, `{"content":"a\nc\n"}`, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusOK, saved.Code)
+	newETag := saved.Header().Get("ETag")
+	require.NotEqual(t, etag, newETag)
+
+	// A second client still holding the original ETag is rejected.
+	stale := fileRequest(t, server.UpdateFile, http.MethodPut, file.ID, userID, `{"content":"a\nb\nd\n"}`, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusPreconditionFailed, stale.Code)
+	var conflict struct {
+		Code    string `json:"code"`
+		ETag    string `json:"etag"`
+		Current struct {
+			Version int `json:"version"`
+		} `json:"current"`
+		Diff struct {
+			FirstDifferenceLine int `json:"first_difference_line"`
+			LinesOnlyInYours    int `json:"lines_only_in_yours"`
+			LinesOnlyInCurrent  int `json:"lines_only_in_current"`
+		} `json:"diff"`
+	}
+	require.NoError(t, json.Unmarshal(stale.Body.Bytes(), &conflict))
+	require.Equal(t, "PRECONDITION_FAILED", conflict.Code)
+	require.Equal(t, newETag, conflict.ETag)
+	require.Equal(t, 2, conflict.Current.Version)
+	require.Equal(t, 2, conflict.Diff.FirstDifferenceLine)
+	require.Equal(t, 2, conflict.Diff.LinesOnlyInYours)
+	require.Equal(t, 1, conflict.Diff.LinesOnlyInCurrent)
+
+	forced := fileRequest(t, server.UpdateFile, http.MethodPut, file.ID, userID, `{"content":"forced"}`, map[string]string{"If-Match": "*"})
+	require.Equal(t, http.StatusOK, forced.Code)
+	var stored models.File
+	require.NoError(t, gormDB.First(&stored, file.ID).Error)
+	require.Equal(t, "forced", stored.Content)
+	require.Equal(t, 3, stored.Version)
+}
+
+func uploadRequest(t *testing.T, server *Server, projectID, userID uint, filePath string, data []byte, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "upload.bin")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, form.WriteField("path", filePath))
+	require.NoError(t, form.Close())
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/projects/%d/files/upload", projectID), &body)
+	context.Request.Header.Set("Content-Type", form.FormDataContentType())
+	for k, v := range headers {
+		context.Request.Header.Set(k, v)
+	}
+	context.Params = gin.Params{{Key: "id", Value: fmt.Sprint(projectID)}}
+	context.Set("user_id", userID)
+	server.UploadFile(context)
+	return recorder
+}
+
+func TestUploadFileStoresBinaryContentInBlobStore(t *testing.T) {
+	server, userID, gormDB := newProjectAPITestServer(t, "pro")
+	blobs, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	server.SetStorageProvider(blobs)
+
+	project := models.Project{Name: "Binary", Language: "html", OwnerID: userID}
+	require.NoError(t, gormDB.Create(&project).Error)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\xff")
+	created := uploadRequest(t, server, project.ID, userID, "/assets/logo.png", png, nil)
+	require.Equal(t, http.StatusCreated, created.Code, created.Body.String())
+
+	var stored models.File
+	require.NoError(t, gormDB.Where("project_id = ? AND path = ?", project.ID, "assets/logo.png").First(&stored).Error)
+	require.True(t, stored.IsBinary)
+	require.Empty(t, stored.Content)
+	require.Equal(t, int64(len(png)), stored.Size)
+	require.Equal(t, "image/png", stored.MimeType)
+	exists, err := blobs.Exists(context.Background(), storage.BlobKey(stored.Hash))
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	raw := fileRequest(t, server.DownloadFile, http.MethodGet, stored.ID, userID, "", nil)
+	require.Equal(t, http.StatusOK, raw.Code)
+	require.Equal(t, png, raw.Body.Bytes())
+	require.Equal(t, "image/png", raw.Header().Get("Content-Type"))
+
+	// Binary files can't be overwritten as text, and replacing needs If-Match.
+	edit := fileRequest(t, server.UpdateFile, http.MethodPut, stored.ID, userID, `{"content":"oops"}`, map[string]string{"If-Match": "*"})
+	require.Equal(t, http.StatusBadRequest, edit.Code)
+	unconditional := uploadRequest(t, server, project.ID, userID, "assets/logo.png", []byte("GIF89a"), nil)
+	require.Equal(t, http.StatusPreconditionRequired, unconditional.Code)
+	replaced := uploadRequest(t, server, project.ID, userID, "assets/logo.png", []byte("GIF89a"), map[string]string{"If-Match": raw.Header().Get("ETag")})
+	require.Equal(t, http.StatusOK, replaced.Code, replaced.Body.String())
+
+	var count int64
+	gormDB.Model(&models.File{}).Where("project_id = ?", project.ID).Count(&count)
+	require.Equal(t, int64(1), count)
+	require.NoError(t, gormDB.First(&stored, stored.ID).Error)
+	require.Equal(t, 2, stored.Version)
+	require.Equal(t, int64(6), stored.Size)
+}
+
+func uploadChunkRequest(t *testing.T, handler gin.HandlerFunc, method, uploadID string, userID uint, offset int64, chunk []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(method, "/api/v1/uploads/"+uploadID, bytes.NewReader(chunk))
+	context.Request.Header.Set("Content-Type", "application/offset+octet-stream")
+	context.Request.Header.Set("Upload-Offset", fmt.Sprint(offset))
+	context.Params = gin.Params{{Key: "uploadId", Value: uploadID}}
+	context.Set("user_id", userID)
+	handler(context)
+	return recorder
+}
+
+func TestResumableUploadAssemblesChunksAndServesRanges(t *testing.T) {
+	server, userID, gormDB := newProjectAPITestServer(t, "free")
+	require.NoError(t, gormDB.AutoMigrate(&models.FileUpload{}))
+	blobs, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	server.SetStorageProvider(blobs)
+
+	project := models.Project{Name: "Dataset", Language: "python", OwnerID: userID}
+	require.NoError(t, gormDB.Create(&project).Error)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/projects/%d/uploads", project.ID), strings.NewReader(body))
+		context.Request.Header.Set("Content-Type", "application/json")
+		context.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+		context.Set("user_id", userID)
+		server.CreateUpload(context)
+		return recorder
+	}
+
+	tooLarge := create(fmt.Sprintf(`{"path":"data/huge.csv","size":%d}`, maxBinaryFileSize+1))
+	require.Equal(t, http.StatusRequestEntityTooLarge, tooLarge.Code)
+
+	data := bytes.Repeat([]byte("id,value\n"), 1000)
+	created := create(fmt.Sprintf(`{"path":"data/rows.csv","size":%d}`, len(data)))
+	require.Equal(t, http.StatusCreated, created.Code, created.Body.String())
+	var session struct {
+		Upload models.FileUpload `json:"upload"`
+	}
+	require.NoError(t, json.Unmarshal(created.Body.Bytes(), &session))
+	uploadID := session.Upload.ID
+
+	first := uploadChunkRequest(t, server.PatchUpload, http.MethodPatch, uploadID, userID, 0, data[:4000])
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+	require.Equal(t, "4000", first.Header().Get("Upload-Offset"))
+
+	// A retried chunk at a stale offset is refused with the current offset.
+	stale := uploadChunkRequest(t, server.PatchUpload, http.MethodPatch, uploadID, userID, 0, data[:4000])
+	require.Equal(t, http.StatusConflict, stale.Code)
+	resume := uploadChunkRequest(t, server.GetUpload, http.MethodHead, uploadID, userID, 0, nil)
+	require.Equal(t, "4000", resume.Header().Get("Upload-Offset"))
+
+	done := uploadChunkRequest(t, server.PatchUpload, http.MethodPatch, uploadID, userID, 4000, data[4000:])
+	require.Equal(t, http.StatusCreated, done.Code, done.Body.String())
+
+	var stored models.File
+	require.NoError(t, gormDB.Where("project_id = ? AND path = ?", project.ID, "data/rows.csv").First(&stored).Error)
+	require.True(t, stored.IsBinary)
+	require.Equal(t, int64(len(data)), stored.Size)
+	var remaining int64
+	gormDB.Model(&models.FileUpload{}).Count(&remaining)
+	require.Zero(t, remaining)
+	exists, err := blobs.Exists(context.Background(), uploadPartKey(uploadID, 0))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	ranged := fileRequest(t, server.DownloadFile, http.MethodGet, stored.ID, userID, "", map[string]string{"Range": "bytes=9-17"})
+	require.Equal(t, http.StatusPartialContent, ranged.Code)
+	require.Equal(t, "id,value\n", ranged.Body.String())
+	require.Equal(t, fmt.Sprintf("bytes 9-17/%d", len(data)), ranged.Header().Get("Content-Range"))
+
+	unsatisfiable := fileRequest(t, server.DownloadFile, http.MethodGet, stored.ID, userID, "", map[string]string{"Range": fmt.Sprintf("bytes=%d-", len(data)+10)})
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, unsatisfiable.Code)
+}
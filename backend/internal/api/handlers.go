@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,11 +17,14 @@ import (
 	"apex-build/internal/cache"
 	"apex-build/internal/db"
 	"apex-build/internal/email"
+	"apex-build/internal/enterprise"
 	appmiddleware "apex-build/internal/middleware"
 	"apex-build/internal/mobile"
+	"apex-build/internal/onboarding"
 	"apex-build/internal/origins"
 	"apex-build/internal/payments"
 	"apex-build/internal/pricing"
+	"apex-build/internal/referrals"
 	"apex-build/internal/startup"
 	"apex-build/internal/storage"
 	"apex-build/internal/usage"
@@ -38,12 +42,18 @@ type Server struct {
 	aiRouter     *ai.AIRouter
 	byok         *ai.BYOKManager
 	usage        *usage.Tracker
+	storageQuota StorageQuota
 	readiness    *startup.Registry
 	storage      storage.Provider
 	cache        func() cache.Status
 	email        *email.Service
 	mobile       *mobile.MobileBuildService
 	mobileSubmit *mobile.MobileSubmissionService
+	instructions ProjectInstructions
+	loginGuard   *auth.LoginGuard
+	onboarding   *onboarding.Service
+	referrals    *referrals.Service
+	symbols      SymbolIndexer
 }
 
 // NewServer creates a new API server
@@ -69,6 +79,116 @@ func (s *Server) SetUsageTracker(tracker *usage.Tracker) {
 	s.usage = tracker
 }
 
+// SetOnboardingService starts guided setup for users when they first sign in
+func (s *Server) SetOnboardingService(service *onboarding.Service) {
+	s.onboarding = service
+}
+
+// SetReferralService attributes signups to the referral link they came from
+func (s *Server) SetReferralService(service *referrals.Service) {
+	s.referrals = service
+}
+
+// attributeReferral links a new user to their referrer using the code from
+// the signup form or the cookie set when they followed a referral link.
+// Referral problems never fail a signup.
+func (s *Server) attributeReferral(c *gin.Context, userID uint, code string) {
+	if s.referrals == nil {
+		return
+	}
+	if code == "" {
+		code, _ = c.Cookie(referrals.CookieName)
+	}
+	if code == "" {
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{Name: referrals.CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	if _, err := s.referrals.AttributeSignup(userID, code, c.ClientIP(), c.GetHeader(auth.DeviceIDHeader)); err != nil &&
+		!errors.Is(err, referrals.ErrUnknownCode) && !errors.Is(err, referrals.ErrSelfReferral) {
+		log.Printf("referral attribution failed for user %d: %v", userID, err)
+	}
+}
+
+// startOnboarding provisions the sample project in the background so sign-in
+// is not held up by template copying.
+func (s *Server) startOnboarding(userID uint) {
+	if s.onboarding == nil {
+		return
+	}
+	go func() {
+		if _, err := s.onboarding.Start(userID); err != nil {
+			log.Printf("onboarding start failed for user %d: %v", userID, err)
+		}
+	}()
+}
+
+// StorageQuota checks file writes against the storage limit of whoever pays
+// for the project, and the caller's plan's per-file size limit.
+type StorageQuota interface {
+	AllowProjectStorageDelta(c *gin.Context, projectID uint, bytes int64) bool
+	MaxFileBytes(c *gin.Context) int64
+}
+
+func (s *Server) SetStorageQuota(quota StorageQuota) {
+	s.storageQuota = quota
+}
+
+// allowStorageDelta reports whether a write growing the project's storage by
+// bytes may proceed; when it may not, the quota response has already been
+// written.
+func (s *Server) allowStorageDelta(c *gin.Context, projectID uint, bytes int64) bool {
+	if s.storageQuota == nil {
+		return true
+	}
+	return s.storageQuota.AllowProjectStorageDelta(c, projectID, bytes)
+}
+
+// maxFileBytes returns the largest single file the caller may store.
+func (s *Server) maxFileBytes(c *gin.Context) int64 {
+	if s.storageQuota == nil {
+		return maxBinaryFileSize
+	}
+	return s.storageQuota.MaxFileBytes(c)
+}
+
+// recordStorageChange records a file size change so cached storage usage is
+// refreshed before the next quota check.
+func (s *Server) recordStorageChange(ctx context.Context, userID, projectID uint, delta int64) {
+	if s.usage == nil || delta == 0 {
+		return
+	}
+	if err := s.usage.RecordStorageChange(ctx, userID, &projectID, delta); err != nil {
+		log.Printf("usage tracker: failed to record storage change for user %d: %v", userID, err)
+	}
+}
+
+// SymbolIndexer keeps the project symbol table current as files are saved
+// and deleted. Implemented by symbols.Service.
+type SymbolIndexer interface {
+	IndexFile(ctx context.Context, file *models.File) error
+	RemoveFile(ctx context.Context, fileID uint) error
+}
+
+// SetSymbolIndexer re-parses files for symbol search when they are saved.
+func (s *Server) SetSymbolIndexer(indexer SymbolIndexer) {
+	s.symbols = indexer
+}
+
+// indexSymbols updates a saved file's symbols in the background so the
+// save response is not held up by parsing.
+func (s *Server) indexSymbols(file models.File) {
+	if s.symbols == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.symbols.IndexFile(ctx, &file); err != nil {
+			log.Printf("symbols: failed to index file %d: %v", file.ID, err)
+		}
+	}()
+}
+
 func (s *Server) SetCacheStatusProvider(provider func() cache.Status) {
 	s.cache = provider
 }
@@ -81,6 +201,17 @@ func (s *Server) SetMobileSubmissionService(service *mobile.MobileSubmissionServ
 	s.mobileSubmit = service
 }
 
+// ProjectInstructions renders a project's AI instructions (apex.md and
+// organization instructions). Implemented by instructions.Service.
+type ProjectInstructions interface {
+	PromptContext(ctx context.Context, userID, projectID uint) (string, error)
+}
+
+// SetProjectInstructions wires project instructions into AI chat prompts.
+func (s *Server) SetProjectInstructions(source ProjectInstructions) {
+	s.instructions = source
+}
+
 // Health endpoint - Returns quickly for load balancer health checks
 func (s *Server) Health(c *gin.Context) {
 	summary := s.runtimeReadinessSummary(false)
@@ -366,6 +497,8 @@ func (s *Server) Register(c *gin.Context) {
 		return
 	}
 
+	s.attributeReferral(c, user.ID, req.ReferralCode)
+
 	// Issue email verification code (best-effort, non-blocking)
 	go func() {
 		if err := s.issueVerificationCode(user); err != nil {
@@ -375,11 +508,12 @@ func (s *Server) Register(c *gin.Context) {
 	}()
 
 	// Generate tokens
-	tokens, err := s.auth.GenerateTokens(user)
+	tokens, err := s.auth.GenerateTokensWithMetadata(user, auth.RequestMetadata(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
+	s.startOnboarding(user.ID)
 
 	auth.SetAccessTokenCookie(c, tokens.AccessToken)
 	auth.SetRefreshTokenCookie(c, tokens.RefreshToken)
@@ -429,16 +563,46 @@ func (s *Server) Login(c *gin.Context) {
 	} else {
 		dbErr = s.db.DB.Where("LOWER(username) = LOWER(?)", identifier).First(&user).Error
 	}
+
+	// Brute-force protection: failures count per account (whichever name
+	// was typed) and per address
+	attempt := auth.LoginAttempt{
+		Identifier:   identifier,
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		CaptchaToken: req.CaptchaToken,
+	}
+	var attemptUserID *uint
+	if dbErr == nil {
+		attempt.Identifier = fmt.Sprintf("user:%d", user.ID)
+		attemptUserID = &user.ID
+	}
+	if s.loginGuard != nil {
+		if denial := s.loginGuard.Check(c.Request.Context(), attempt); denial != nil {
+			s.loginDenied(c, denial)
+			return
+		}
+	}
+
 	if dbErr != nil {
+		if s.loginGuard != nil {
+			s.loginGuard.RecordFailure(c.Request.Context(), attempt, nil)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if err := s.auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		if s.loginGuard != nil {
+			s.loginGuard.RecordFailure(c.Request.Context(), attempt, attemptUserID)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	if s.loginGuard != nil {
+		s.loginGuard.RecordSuccess(c.Request.Context(), attempt)
+	}
 
 	// Check if user is active
 	if !user.IsActive {
@@ -458,11 +622,12 @@ func (s *Server) Login(c *gin.Context) {
 	}
 
 	// Generate tokens
-	tokens, err := s.auth.GenerateTokens(&user)
+	tokens, err := s.auth.GenerateTokensWithMetadata(&user, auth.RequestMetadata(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
+	s.startOnboarding(user.ID)
 
 	auth.SetAccessTokenCookie(c, tokens.AccessToken)
 	auth.SetRefreshTokenCookie(c, tokens.RefreshToken)
@@ -508,7 +673,7 @@ func (s *Server) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	tokens, err := s.auth.RefreshTokens(refreshToken, nil)
+	tokens, err := s.auth.RefreshSession(refreshToken, auth.RequestMetadata(c))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "invalid refresh token",
@@ -598,6 +763,7 @@ func (s *Server) AIGenerate(c *gin.Context) {
 		Temperature: request.Temperature,
 		Provider:    ai.AIProvider(provider),
 		Model:       request.Model,
+		ModelScope:  ai.ModelScopeChat,
 		UserID:      fmt.Sprintf("%d", uid),
 		ProjectID:   request.ProjectID,
 	}
@@ -606,6 +772,17 @@ func (s *Server) AIGenerate(c *gin.Context) {
 		aiReq.Temperature = 0.7
 	}
 
+	// Project and organization instructions lead the prompt
+	if s.instructions != nil && request.ProjectID != "" {
+		if projectID, err := strconv.ParseUint(request.ProjectID, 10, 32); err == nil {
+			if block, err := s.instructions.PromptContext(c.Request.Context(), uid, uint(projectID)); err != nil {
+				log.Printf("AI generate: failed to load instructions for project %d: %v", projectID, err)
+			} else if block = strings.TrimSpace(block); block != "" {
+				aiReq.Prompt = block + "\n\n---\n\n" + aiReq.Prompt
+			}
+		}
+	}
+
 	// Select router with BYOK awareness
 	targetRouter := s.aiRouter
 	isBYOK := false
@@ -664,7 +841,14 @@ func (s *Server) AIGenerate(c *gin.Context) {
 		if s.byok != nil && reservation != nil {
 			_ = s.byok.FinalizeCredits(reservation, 0)
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, ai.ErrNoCompliantProvider):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "code": "AI_REGION_UNAVAILABLE", "allowed_regions": aiReq.AllowedRegions})
+		case errors.Is(err, enterprise.ErrAIRegionPolicyConflict):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "AI_REGION_POLICY_CONFLICT"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	actualProvider := ai.ActualProvider(response, aiReq.Provider)
@@ -675,6 +859,8 @@ func (s *Server) AIGenerate(c *gin.Context) {
 		UserID:     uid,
 		Provider:   string(actualProvider),
 		Capability: string(aiReq.Capability),
+		Model:      ai.GetModelUsed(response, aiReq),
+		Region:     ai.ResponseRegion(response),
 		Prompt:     aiReq.Prompt,
 		Code:       aiReq.Code,
 		Language:   aiReq.Language,
@@ -727,6 +913,9 @@ func (s *Server) AIGenerate(c *gin.Context) {
 				response.Usage.Cost = cost
 			}
 			dbRequest.Cost = cost
+			if isBYOK {
+				dbRequest.APIKeyID = s.byok.ActiveKeyID(uid, string(actualProvider))
+			}
 			s.byok.RecordUsage(uid, projectID, string(actualProvider), modelUsed, isBYOK,
 				inputTokens, outputTokens, cost, string(aiReq.Capability), response.Duration, "success")
 			if reservation != nil {
@@ -801,6 +990,32 @@ func (s *Server) GetAIUsage(c *gin.Context) {
 	})
 }
 
+// GetAIProviderHealth returns each platform provider's health-check status
+// together with its circuit breaker state and recent error classes.
+func (s *Server) GetAIProviderHealth(c *gin.Context) {
+	if s.aiRouter == nil {
+		c.JSON(http.StatusOK, gin.H{"providers": gin.H{}, "checked_at": time.Now().UTC()})
+		return
+	}
+
+	health := s.aiRouter.GetDetailedHealthStatus()
+	circuits := s.aiRouter.GetCircuitStatus()
+	providers := make(map[string]gin.H, len(health))
+	for provider, detail := range health {
+		entry := gin.H{"circuit": circuits[provider]}
+		if detail != nil {
+			entry["status"] = detail.Status
+			entry["detail"] = detail.Detail
+		}
+		providers[provider] = entry
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"providers":  providers,
+		"checked_at": time.Now().UTC(),
+	})
+}
+
 type ProviderStat struct {
 	Requests int     `json:"requests"`
 	Cost     float64 `json:"cost"`
@@ -934,6 +1149,10 @@ func (s *Server) CreateFile(c *gin.Context) {
 		return
 	}
 
+	if !s.allowStorageDelta(c, project.ID, int64(len(req.Content))) {
+		return
+	}
+
 	projectIDUint, _ := strconv.ParseUint(projectID, 10, 32)
 	file := &models.File{
 		ProjectID:  uint(projectIDUint),
@@ -950,7 +1169,10 @@ func (s *Server) CreateFile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
 		return
 	}
+	s.recordStorageChange(c.Request.Context(), uid, file.ProjectID, file.Size)
+	s.indexSymbols(*file)
 
+	c.Header("ETag", fileETag(file))
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "File created successfully",
 		"file":    file,
@@ -1006,6 +1228,10 @@ func (s *Server) GetFile(c *gin.Context) {
 		return
 	}
 
+	if appmiddleware.NotModified(c, fileETag(&file)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"file": file,
 	})
@@ -1068,6 +1294,23 @@ func (s *Server) DownloadProject(c *gin.Context) {
 			continue
 		}
 
+		// Binary files stream from the blob store
+		if file.IsBinary {
+			if s.storage == nil || !storage.ValidBlobHash(file.Hash) {
+				continue
+			}
+			reader, _, err := s.storage.Get(c.Request.Context(), storage.BlobKey(file.Hash))
+			if err != nil {
+				log.Printf("download project %d: blob for %s unavailable: %v", project.ID, file.Path, err)
+				continue
+			}
+			if _, err := io.Copy(w, reader); err != nil {
+				log.Printf("download project %d: failed to write %s: %v", project.ID, file.Path, err)
+			}
+			reader.Close()
+			continue
+		}
+
 		// Write content
 		if _, err := w.Write([]byte(file.Content)); err != nil {
 			continue
@@ -1196,6 +1439,37 @@ func (s *Server) UpdateFile(c *gin.Context) {
 		return
 	}
 
+	if file.IsBinary && req.Content != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Binary files can't be edited as text; upload a replacement to /projects/:id/files/upload",
+			"code":  "BINARY_FILE",
+		})
+		return
+	}
+
+	// Require the ETag the client last saw so concurrent editors (or a user
+	// and an agent) can't silently overwrite each other.
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error": "If-Match header is required; send the file's ETag or * to overwrite",
+			"code":  "PRECONDITION_REQUIRED",
+		})
+		return
+	}
+	if !appmiddleware.ETagMatches(ifMatch, fileETag(&file)) {
+		s.respondFileConflict(c, &file, req.Content)
+		return
+	}
+
+	var sizeDelta int64
+	if req.Content != nil {
+		sizeDelta = int64(len(*req.Content)) - file.Size
+		if !s.allowStorageDelta(c, file.ProjectID, sizeDelta) {
+			return
+		}
+	}
+
 	// Update file (content and/or metadata)
 	tx := s.db.DB.Begin()
 	if tx.Error != nil {
@@ -1256,6 +1530,27 @@ func (s *Server) UpdateFile(c *gin.Context) {
 		file.Size = int64(len(*req.Content))
 	}
 
+	// Claim the version we checked; a concurrent writer that got there
+	// first leaves no row to bump.
+	claim := tx.Model(&models.File{}).
+		Where("id = ? AND version = ?", file.ID, file.Version).
+		Update("version", file.Version+1)
+	if claim.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+		return
+	}
+	if claim.RowsAffected == 0 {
+		tx.Rollback()
+		var current models.File
+		if err := s.db.DB.Where("id = ?", file.ID).First(&current).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		s.respondFileConflict(c, &current, req.Content)
+		return
+	}
+
 	file.LastEditBy = uid
 	file.Version++
 
@@ -1269,13 +1564,94 @@ func (s *Server) UpdateFile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit file update"})
 		return
 	}
+	s.recordStorageChange(c.Request.Context(), uid, file.ProjectID, sizeDelta)
+	s.indexSymbols(file)
 
+	c.Header("ETag", fileETag(&file))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "File updated successfully",
 		"file":    file,
 	})
 }
 
+// fileETag is the strong ETag of a file: it changes whenever the file is
+// renamed, moved or its content changes, including writes that don't bump
+// Version. Binary files change through their blob hash.
+func fileETag(file *models.File) string {
+	return appmiddleware.StrongETag(
+		strconv.FormatUint(uint64(file.ID), 10),
+		strconv.Itoa(file.Version),
+		file.Path,
+		file.Content,
+		file.Hash,
+	)
+}
+
+// respondFileConflict answers a failed If-Match with 412, the current ETag and
+// a hint of how the rejected content differs from what's stored now.
+func (s *Server) respondFileConflict(c *gin.Context, current *models.File, proposed *string) {
+	etag := fileETag(current)
+	c.Header("ETag", etag)
+	response := gin.H{
+		"error": "File was modified since you last loaded it",
+		"code":  "PRECONDITION_FAILED",
+		"etag":  etag,
+		"current": gin.H{
+			"version":      current.Version,
+			"path":         current.Path,
+			"size":         current.Size,
+			"last_edit_by": current.LastEditBy,
+			"updated_at":   current.UpdatedAt,
+		},
+	}
+	if proposed != nil {
+		response["diff"] = fileConflictDiff(current.Content, *proposed)
+	}
+	c.JSON(http.StatusPreconditionFailed, response)
+}
+
+// fileConflictDiff summarizes how proposed differs from current: line counts
+// in each direction and the first line where they diverge (1-based, 0 when
+// identical), enough for a client to decide whether to merge or reload.
+func fileConflictDiff(current, proposed string) gin.H {
+	currentLines := strings.Split(current, "\n")
+	proposedLines := strings.Split(proposed, "\n")
+
+	firstDifference := 0
+	for i := 0; i < len(currentLines) || i < len(proposedLines); i++ {
+		if i >= len(currentLines) || i >= len(proposedLines) || currentLines[i] != proposedLines[i] {
+			firstDifference = i + 1
+			break
+		}
+	}
+
+	counts := make(map[string]int, len(currentLines))
+	for _, line := range currentLines {
+		counts[line]++
+	}
+	added := 0
+	for _, line := range proposedLines {
+		if counts[line] > 0 {
+			counts[line]--
+		} else {
+			added++
+		}
+	}
+	removed := 0
+	for _, n := range counts {
+		removed += n
+	}
+
+	return gin.H{
+		"identical":             current == proposed,
+		"first_difference_line": firstDifference,
+		"lines_only_in_yours":   added,
+		"lines_only_in_current": removed,
+		"current_lines":         len(currentLines),
+		"your_lines":            len(proposedLines),
+	}
+}
+
 // DeleteFile deletes a file by ID
 func (s *Server) DeleteFile(c *gin.Context) {
 	fileID := c.Param("id")
@@ -1299,6 +1675,12 @@ func (s *Server) DeleteFile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
 		return
 	}
+	s.recordStorageChange(c.Request.Context(), uid, file.ProjectID, -file.Size)
+	if s.symbols != nil {
+		if err := s.symbols.RemoveFile(c.Request.Context(), file.ID); err != nil {
+			log.Printf("symbols: failed to drop symbols of file %d: %v", file.ID, err)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "File deleted successfully",
@@ -1336,6 +1718,8 @@ func (s *Server) AuthMiddleware() gin.HandlerFunc {
 		c.Set("has_unlimited_credits", claims.HasUnlimitedCredits)
 		c.Set("bypass_billing", claims.BypassBilling)
 		c.Set("bypass_rate_limits", claims.BypassRateLimits)
+		c.Set("session_id", claims.SessionID)
+		s.auth.TouchSession(claims.SessionID, c.ClientIP())
 		c.Next()
 	}
 }
@@ -1351,8 +1735,8 @@ func (s *Server) CORSMiddleware() gin.HandlerFunc {
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, X-Apex-Operation-ID, X-Apex-Client-Trace-ID, X-Apex-Build-Poll-Token")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Apex-Operation-ID")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, X-Apex-Operation-ID, X-Apex-Client-Trace-ID, X-Apex-Build-Poll-Token, X-Apex-Device-ID, If-Match, If-None-Match, Range, Upload-Offset")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Apex-Operation-ID, ETag, Location, Content-Range, Accept-Ranges, Upload-Offset, Upload-Length, Upload-Expires")
 		c.Header("Access-Control-Max-Age", "86400") // 24 hours preflight cache
 
 		if c.Request.Method == "OPTIONS" {
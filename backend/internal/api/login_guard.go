@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/auth"
+	"apex-build/internal/enterprise"
+	"apex-build/internal/notifications"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetLoginGuard enables brute-force protection on password logins.
+func (s *Server) SetLoginGuard(guard *auth.LoginGuard) {
+	s.loginGuard = guard
+}
+
+// loginDenied reports a refused login attempt. Lockouts answer 429 with
+// Retry-After; CAPTCHA challenges name the provider and site key so the
+// client can render the widget and retry with captcha_token.
+func (s *Server) loginDenied(c *gin.Context, denial *auth.LoginDenial) {
+	if denial.CaptchaRequired {
+		response := gin.H{
+			"error":      "Please complete the verification challenge to continue.",
+			"error_code": "captcha_required",
+		}
+		if errors.Is(denial.Err, auth.ErrCaptchaFailed) {
+			response["error"] = "Verification challenge failed. Please try again."
+			response["error_code"] = "captcha_failed"
+		}
+		if verifier := s.loginGuard.CaptchaVerifier(); verifier != nil {
+			response["captcha"] = gin.H{
+				"provider": verifier.Provider(),
+				"site_key": verifier.SiteKey(),
+			}
+		}
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	retryAfter := int(denial.RetryAfter.Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	code := "account_locked"
+	message := "Too many failed login attempts. Try again later."
+	if errors.Is(denial.Err, auth.ErrIPBlocked) {
+		code = "ip_blocked"
+		message = "Too many failed login attempts from this network. Try again later."
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"error_code":  code,
+		"retry_after": retryAfter,
+	})
+}
+
+// LoginSecurityEventHandler records login security events in the audit log
+// and tells account owners when their account is locked.
+func LoginSecurityEventHandler(audit *enterprise.AuditService, notifier *notifications.Service) func(auth.SecurityEvent) {
+	return func(event auth.SecurityEvent) {
+		severity := "warning"
+		switch event.Type {
+		case auth.SecurityEventLoginFailed:
+			severity = "info"
+		case auth.SecurityEventIPBlocked:
+			severity = "critical"
+		}
+		metadata := map[string]interface{}{
+			"failures": event.Failures,
+		}
+		if !event.LockedUntil.IsZero() {
+			metadata["locked_until"] = event.LockedUntil
+		}
+
+		if audit != nil {
+			audit.LogEvent(&enterprise.AuditLog{
+				UserID:       event.UserID,
+				IPAddress:    event.IPAddress,
+				UserAgent:    event.UserAgent,
+				Action:       event.Type,
+				ResourceType: "user",
+				ResourceID:   event.Identifier,
+				Category:     "authentication",
+				Severity:     severity,
+				Outcome:      "failure",
+				Description:  securityEventDescription(event),
+				Metadata:     metadata,
+			})
+		}
+		if event.Type != auth.SecurityEventLoginFailed {
+			log.Printf("[auth] security event %s: %s from %s (%d failures)", event.Type, event.Identifier, event.IPAddress, event.Failures)
+		}
+
+		if notifier != nil && event.UserID != nil && event.Type == auth.SecurityEventAccountLocked {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = notifier.Notify(ctx, &notifications.Notification{
+				UserID:   *event.UserID,
+				Kind:     "account_locked",
+				Severity: notifications.SeverityCritical,
+				Title:    "Sign-in locked after failed attempts",
+				Body: fmt.Sprintf("Your account saw %d failed sign-in attempts, most recently from %s, and is locked until %s. If this wasn't you, change your password.",
+					event.Failures, event.IPAddress, event.LockedUntil.UTC().Format(time.RFC1123)),
+				Metadata: metadata,
+			})
+		}
+	}
+}
+
+func securityEventDescription(event auth.SecurityEvent) string {
+	switch event.Type {
+	case auth.SecurityEventAccountLocked:
+		return "Account locked after repeated failed logins"
+	case auth.SecurityEventIPSuspicious:
+		return "Address flagged for repeated failed logins; CAPTCHA required"
+	case auth.SecurityEventIPBlocked:
+		return "Address blocked for repeated failed logins"
+	case auth.SecurityEventCaptchaFailed:
+		return "Login CAPTCHA verification failed"
+	}
+	return "Failed login"
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"apex-build/internal/auth"
+	appmiddleware "apex-build/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserSessions lists the devices signed in to the current user's account
+func (s *Server) GetUserSessions(c *gin.Context) {
+	userID, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := s.auth.ListSessions(userID, appmiddleware.GetSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"sessions": sessions,
+	})
+}
+
+// RevokeUserSession signs one of the current user's devices out
+func (s *Server) RevokeUserSession(c *gin.Context) {
+	userID, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID := c.Param("sessionId")
+	if err := s.auth.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	current := sessionID == appmiddleware.GetSessionID(c)
+	if current {
+		auth.ClearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"current": current,
+	})
+}
+
+// RevokeAllUserSessions signs the current user out of every other device,
+// or every device including this one with ?include_current=true
+func (s *Server) RevokeAllUserSessions(c *gin.Context) {
+	userID, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	includeCurrent := c.Query("include_current") == "true"
+	keep := appmiddleware.GetSessionID(c)
+	if includeCurrent {
+		keep = ""
+	}
+	revoked, err := s.auth.RevokeAllSessions(userID, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if includeCurrent {
+		if token, err := auth.AccessTokenFromRequest(c); err == nil && token != "" {
+			_ = s.auth.BlacklistToken(token)
+		}
+		auth.ClearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"revoked": revoked,
+	})
+}
@@ -0,0 +1,400 @@
+// APEX.BUILD Resumable Upload Handlers
+// tus-style chunked uploads for project files too large for a single
+// request body. Clients create an upload, PATCH chunks at the current
+// Upload-Offset, and resume after a failure by asking for the offset again.
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	appmiddleware "apex-build/internal/middleware"
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	uploadChunkSize    = 5 * 1024 * 1024 // suggested chunk size
+	maxUploadChunkSize = 8 * 1024 * 1024 // largest chunk accepted per PATCH
+	uploadTTL          = 24 * time.Hour
+)
+
+// uploadPartKey is the storage key of the chunk that starts at offset.
+// Offsets are zero-padded so parts list in order.
+func uploadPartKey(uploadID string, offset int64) string {
+	return fmt.Sprintf("uploads/%s/%020d", uploadID, offset)
+}
+
+func uploadURL(uploadID string) string {
+	return "/api/v1/uploads/" + uploadID
+}
+
+func setUploadHeaders(c *gin.Context, upload *models.FileUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-store")
+}
+
+func uploadResponse(upload *models.FileUpload) gin.H {
+	return gin.H{
+		"upload":         upload,
+		"upload_url":     uploadURL(upload.ID),
+		"offset":         upload.Received,
+		"chunk_size":     uploadChunkSize,
+		"max_chunk_size": maxUploadChunkSize,
+	}
+}
+
+// CreateUpload handles POST /api/v1/projects/:id/uploads. It opens a
+// resumable upload for a file of the declared size after checking the plan's
+// per-file limit and storage quota. Replacing an existing file requires
+// If-Match, like PUT /files/:id; the match is re-checked on completion.
+func (s *Server) CreateUpload(c *gin.Context) {
+	projectID := c.Param("id")
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	if s.storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage not available"})
+		return
+	}
+
+	var req struct {
+		Path     string `json:"path" binding:"required"`
+		Size     int64  `json:"size"`
+		MimeType string `json:"mime_type"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	filePath, ok := cleanUploadPath(req.Path)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file path"})
+		return
+	}
+	if req.Size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be the file's total size in bytes"})
+		return
+	}
+	if maxSize := s.maxFileBytes(c); req.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":          fmt.Sprintf("File too large: %.1f MB (your plan allows %.0f MB per file)", float64(req.Size)/1024/1024, float64(maxSize)/1024/1024),
+			"code":           "FILE_TOO_LARGE",
+			"max_file_bytes": maxSize,
+		})
+		return
+	}
+
+	var project models.Project
+	if err := s.db.DB.Where("id = ? AND owner_id = ?", projectID, uid).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	mimeType := strings.TrimSpace(req.MimeType)
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(filePath)))
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	upload := models.FileUpload{
+		ID:        uuid.New().String(),
+		ProjectID: project.ID,
+		UserID:    uid,
+		Path:      filePath,
+		MimeType:  mimeType,
+		Size:      req.Size,
+		ExpiresAt: time.Now().Add(uploadTTL),
+	}
+
+	var existing models.File
+	if s.db.DB.Where("project_id = ? AND path = ?", project.ID, filePath).Limit(1).Find(&existing).Error == nil && existing.ID != 0 {
+		if existing.Type == "directory" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A directory already exists at that path"})
+			return
+		}
+		ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+		if ifMatch == "" {
+			c.JSON(http.StatusPreconditionRequired, gin.H{
+				"error": "If-Match header is required to replace an existing file; send its ETag or * to overwrite",
+				"code":  "PRECONDITION_REQUIRED",
+			})
+			return
+		}
+		if !appmiddleware.ETagMatches(ifMatch, fileETag(&existing)) {
+			s.respondFileConflict(c, &existing, nil)
+			return
+		}
+		upload.ReplaceFileID = existing.ID
+		if ifMatch != "*" {
+			upload.ReplaceVersion = existing.Version
+		}
+	}
+
+	if !s.allowStorageDelta(c, project.ID, req.Size-existing.Size) {
+		return
+	}
+
+	s.purgeExpiredUploads(c, uid)
+	if err := s.db.DB.Create(&upload).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	setUploadHeaders(c, &upload)
+	c.Header("Location", uploadURL(upload.ID))
+	c.JSON(http.StatusCreated, uploadResponse(&upload))
+}
+
+// loadUpload fetches the caller's upload, treating expired ones as gone.
+func (s *Server) loadUpload(c *gin.Context, uid uint) (*models.FileUpload, bool) {
+	var upload models.FileUpload
+	if err := s.db.DB.Where("id = ? AND user_id = ?", c.Param("uploadId"), uid).First(&upload).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		s.discardUpload(c, &upload)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload expired"})
+		return nil, false
+	}
+	return &upload, true
+}
+
+// GetUpload handles HEAD and GET /api/v1/uploads/:uploadId. Clients resume
+// an interrupted upload from the returned Upload-Offset.
+func (s *Server) GetUpload(c *gin.Context) {
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	upload, ok := s.loadUpload(c, uid)
+	if !ok {
+		return
+	}
+	setUploadHeaders(c, upload)
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusOK, uploadResponse(upload))
+}
+
+// PatchUpload handles PATCH /api/v1/uploads/:uploadId. The body is the next
+// chunk and Upload-Offset must equal the bytes received so far. The chunk
+// that completes the upload assembles the file; if that step fails, a PATCH
+// with an empty body at the final offset retries it.
+func (s *Server) PatchUpload(c *gin.Context) {
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	if s.storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage not available"})
+		return
+	}
+	upload, ok := s.loadUpload(c, uid)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header is required"})
+		return
+	}
+	if offset != upload.Received {
+		setUploadHeaders(c, upload)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Upload-Offset does not match the bytes received",
+			"code":   "OFFSET_MISMATCH",
+			"offset": upload.Received,
+		})
+		return
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(c.Request.Body, maxUploadChunkSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read chunk"})
+		return
+	}
+	if len(chunk) > maxUploadChunkSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Chunk too large (max %d MB)", maxUploadChunkSize/1024/1024)})
+		return
+	}
+	received := offset + int64(len(chunk))
+	if received > upload.Size {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk extends past the declared upload size"})
+		return
+	}
+
+	if len(chunk) > 0 {
+		// Claim the byte range first so two clients can't write the same part.
+		result := s.db.DB.Model(&models.FileUpload{}).
+			Where("id = ? AND received = ?", upload.ID, offset).
+			Update("received", received)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update upload"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another chunk was written at this offset", "code": "OFFSET_MISMATCH"})
+			return
+		}
+		if err := s.storage.Put(c.Request.Context(), uploadPartKey(upload.ID, offset), bytes.NewReader(chunk), int64(len(chunk)), "application/octet-stream"); err != nil {
+			s.db.DB.Model(&models.FileUpload{}).
+				Where("id = ? AND received = ?", upload.ID, received).
+				Update("received", offset)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+			return
+		}
+		upload.Received = received
+	}
+
+	if upload.Received < upload.Size {
+		setUploadHeaders(c, upload)
+		c.JSON(http.StatusOK, uploadResponse(upload))
+		return
+	}
+	s.completeUpload(c, uid, upload)
+}
+
+// DeleteUpload handles DELETE /api/v1/uploads/:uploadId, aborting the upload
+// and discarding any chunks received.
+func (s *Server) DeleteUpload(c *gin.Context) {
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	upload, ok := s.loadUpload(c, uid)
+	if !ok {
+		return
+	}
+	s.discardUpload(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// completeUpload streams the received chunks into the blob store and creates
+// or replaces the target file.
+func (s *Server) completeUpload(c *gin.Context, uid uint, upload *models.FileUpload) {
+	var current models.File
+	found := s.db.DB.Where("project_id = ? AND path = ?", upload.ProjectID, upload.Path).Limit(1).Find(&current).Error == nil && current.ID != 0
+	switch {
+	case found && current.ID != upload.ReplaceFileID:
+		// Someone else created a file at this path since the upload began.
+		s.discardUpload(c, upload)
+		s.respondFileConflict(c, &current, nil)
+		return
+	case !found && upload.ReplaceFileID != 0:
+		s.discardUpload(c, upload)
+		c.JSON(http.StatusConflict, gin.H{"error": "The file being replaced was deleted"})
+		return
+	case found && current.Type == "directory":
+		s.discardUpload(c, upload)
+		c.JSON(http.StatusConflict, gin.H{"error": "A directory already exists at that path"})
+		return
+	}
+
+	if !s.allowStorageDelta(c, upload.ProjectID, upload.Size-current.Size) {
+		return
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		var err error
+		for offset := int64(0); offset < upload.Size && err == nil; {
+			var part io.ReadCloser
+			var size int64
+			part, size, err = s.storage.Get(c.Request.Context(), uploadPartKey(upload.ID, offset))
+			if err != nil {
+				break
+			}
+			_, err = io.Copy(writer, part)
+			part.Close()
+			if size <= 0 {
+				err = errors.New("empty upload part")
+			}
+			offset += size
+		}
+		writer.CloseWithError(err)
+	}()
+	hash, size, err := storage.PutBlob(c.Request.Context(), s.storage, reader, upload.Size, upload.MimeType)
+	reader.Close()
+	if err != nil || size != upload.Size {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assemble upload; retry with an empty PATCH at the final offset"})
+		return
+	}
+
+	var replace *models.File
+	expectVersion := 0
+	status := http.StatusCreated
+	if found {
+		replace = &current
+		expectVersion = upload.ReplaceVersion
+		if expectVersion == 0 {
+			expectVersion = current.Version
+		}
+		status = http.StatusOK
+	}
+	file, ok := s.saveBinaryFile(c, uid, upload.ProjectID, upload.Path, upload.MimeType, hash, size, replace, expectVersion)
+	s.discardUpload(c, upload)
+	if !ok {
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Header("ETag", fileETag(&file))
+	c.JSON(status, gin.H{
+		"message": "File uploaded successfully",
+		"file":    file,
+		"raw_url": fileRawURL(file.ID),
+	})
+}
+
+// discardUpload deletes an upload's chunks and its row. Parts are keyed by
+// offset, so each one's size leads to the next.
+func (s *Server) discardUpload(c *gin.Context, upload *models.FileUpload) {
+	if s.storage != nil {
+		for offset := int64(0); offset < upload.Received; {
+			key := uploadPartKey(upload.ID, offset)
+			part, size, err := s.storage.Get(c.Request.Context(), key)
+			if err != nil {
+				break
+			}
+			part.Close()
+			if size <= 0 {
+				break
+			}
+			s.storage.Delete(c.Request.Context(), key)
+			offset += size
+		}
+	}
+	s.db.DB.Delete(&models.FileUpload{}, "id = ?", upload.ID)
+}
+
+// purgeExpiredUploads lazily cleans up a user's abandoned uploads.
+func (s *Server) purgeExpiredUploads(c *gin.Context, uid uint) {
+	var expired []models.FileUpload
+	s.db.DB.Where("user_id = ? AND expires_at < ?", uid, time.Now()).Limit(20).Find(&expired)
+	for i := range expired {
+		s.discardUpload(c, &expired[i])
+	}
+}
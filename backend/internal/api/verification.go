@@ -211,7 +211,7 @@ func (s *Server) VerifyEmail(c *gin.Context) {
 		"message":           "Email verified successfully",
 		"email_verified_at": now,
 	}
-	if tokens, err := s.auth.GenerateTokens(&user); err == nil {
+	if tokens, err := s.auth.GenerateTokensWithMetadata(&user, auth.RequestMetadata(c)); err == nil {
 		auth.SetAccessTokenCookie(c, tokens.AccessToken)
 		auth.SetRefreshTokenCookie(c, tokens.RefreshToken)
 		resp["access_token"] = tokens.AccessToken
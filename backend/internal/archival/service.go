@@ -0,0 +1,312 @@
+// Package archival moves inactive projects to cold storage. Archiving packs
+// a project's files into a gzip'd tarball in the artifact store's cold tier
+// and removes them from the files table; unarchiving restores them.
+package archival
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const manifestName = "manifest.json"
+
+var (
+	// ErrNotFound is returned when the project does not exist for the user.
+	ErrNotFound = errors.New("project not found")
+	// ErrAlreadyArchived is returned when archiving an archived project.
+	ErrAlreadyArchived = errors.New("project is already archived")
+	// ErrNotArchived is returned when unarchiving a live project.
+	ErrNotArchived = errors.New("project is not archived")
+	// ErrStorageUnavailable is returned when no artifact store is configured.
+	ErrStorageUnavailable = errors.New("archive storage is not configured")
+)
+
+// archivedFile is the manifest entry for one file. Content lives in the
+// tarball under files/<path>, except for binary files whose bytes stay in
+// the blob store under Hash.
+type archivedFile struct {
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	MimeType   string `json:"mime_type,omitempty"`
+	Hash       string `json:"hash,omitempty"`
+	IsBinary   bool   `json:"is_binary,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Version    int    `json:"version"`
+	LastEditBy uint   `json:"last_edit_by,omitempty"`
+}
+
+// Result summarizes an archive or unarchive operation.
+type Result struct {
+	ProjectID        uint       `json:"project_id"`
+	Files            int        `json:"files"`
+	OriginalBytes    int64      `json:"original_bytes"`
+	ArchiveSizeBytes int64      `json:"archive_size_bytes"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty"`
+}
+
+// Service archives and restores projects.
+type Service struct {
+	db    *gorm.DB
+	store storage.Provider
+}
+
+// NewService creates a new archival Service.
+func NewService(db *gorm.DB, store storage.Provider) *Service {
+	return &Service{db: db, store: store}
+}
+
+// ArchiveKey returns the object key for a project's archive.
+func ArchiveKey(projectID uint, at time.Time) string {
+	return fmt.Sprintf("archives/projects/%d/%d.tar.gz", projectID, at.Unix())
+}
+
+// Archive compresses the project's files to cold storage, deletes them from
+// the files table, and marks the project archived.
+func (s *Service) Archive(ctx context.Context, userID, projectID uint) (*Result, error) {
+	if s.store == nil {
+		return nil, ErrStorageUnavailable
+	}
+	project, err := s.ownedProject(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project.IsArchived {
+		return nil, ErrAlreadyArchived
+	}
+
+	var files []models.File
+	if err := s.db.WithContext(ctx).Where("project_id = ?", project.ID).Order("path").Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("archival: load files failed: %w", err)
+	}
+
+	data, originalBytes, err := packFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	key := ArchiveKey(project.ID, now)
+	if err := storage.PutCold(ctx, s.store, key, bytes.NewReader(data), int64(len(data)), "application/gzip"); err != nil {
+		return nil, fmt.Errorf("archival: upload archive failed: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("project_id = ? AND deleted_at IS NULL", project.ID).Delete(&models.File{}).Error; err != nil {
+			return fmt.Errorf("archival: remove files failed: %w", err)
+		}
+		return tx.Model(project).Updates(map[string]interface{}{
+			"is_archived":        true,
+			"archived_at":        now,
+			"archive_key":        key,
+			"archive_size_bytes": int64(len(data)),
+		}).Error
+	})
+	if err != nil {
+		// Leave no orphaned object behind when the DB side fails.
+		_ = s.store.Delete(ctx, key)
+		return nil, err
+	}
+
+	return &Result{
+		ProjectID:        project.ID,
+		Files:            len(files),
+		OriginalBytes:    originalBytes,
+		ArchiveSizeBytes: int64(len(data)),
+		ArchivedAt:       &now,
+	}, nil
+}
+
+// Unarchive restores the project's files from cold storage and clears the
+// archived state.
+func (s *Service) Unarchive(ctx context.Context, userID, projectID uint) (*Result, error) {
+	project, err := s.ownedProject(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if !project.IsArchived {
+		return nil, ErrNotArchived
+	}
+
+	archiveKey := project.ArchiveKey
+	var files []models.File
+	if archiveKey != "" {
+		if s.store == nil {
+			return nil, ErrStorageUnavailable
+		}
+		reader, _, err := s.store.Get(ctx, archiveKey)
+		if err != nil {
+			return nil, fmt.Errorf("archival: download archive failed: %w", err)
+		}
+		files, err = unpackFiles(reader, project.ID)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var restoredBytes int64
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range files {
+			restoredBytes += files[i].Size
+			if err := tx.Create(&files[i]).Error; err != nil {
+				return fmt.Errorf("archival: restore %s failed: %w", files[i].Path, err)
+			}
+		}
+		return tx.Model(project).Updates(map[string]interface{}{
+			"is_archived":        false,
+			"archived_at":        nil,
+			"archive_key":        "",
+			"archive_size_bytes": 0,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if archiveKey != "" {
+		_ = s.store.Delete(ctx, archiveKey)
+	}
+
+	return &Result{
+		ProjectID:     project.ID,
+		Files:         len(files),
+		OriginalBytes: restoredBytes,
+	}, nil
+}
+
+func (s *Service) ownedProject(ctx context.Context, userID, projectID uint) (*models.Project, error) {
+	var project models.Project
+	err := s.db.WithContext(ctx).Where("id = ? AND owner_id = ?", projectID, userID).First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("archival: load project failed: %w", err)
+	}
+	return &project, nil
+}
+
+func packFiles(files []models.File) ([]byte, int64, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	manifest := make([]archivedFile, 0, len(files))
+	var originalBytes int64
+	for _, f := range files {
+		manifest = append(manifest, archivedFile{
+			Path:       f.Path,
+			Name:       f.Name,
+			Type:       f.Type,
+			MimeType:   f.MimeType,
+			Hash:       f.Hash,
+			IsBinary:   f.IsBinary,
+			Size:       f.Size,
+			Version:    f.Version,
+			LastEditBy: f.LastEditBy,
+		})
+		if f.Type == "directory" || f.IsBinary {
+			continue
+		}
+		originalBytes += int64(len(f.Content))
+		if err := writeTarEntry(tw, path.Join("files", f.Path), []byte(f.Content)); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("archival: encode manifest failed: %w", err)
+	}
+	if err := writeTarEntry(tw, manifestName, manifestJSON); err != nil {
+		return nil, 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("archival: finalize tar failed: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, 0, fmt.Errorf("archival: finalize gzip failed: %w", err)
+	}
+	return buf.Bytes(), originalBytes, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+		return fmt.Errorf("archival: write %s header failed: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("archival: write %s failed: %w", name, err)
+	}
+	return nil
+}
+
+func unpackFiles(r io.Reader, projectID uint) ([]models.File, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("archival: open archive failed: %w", err)
+	}
+	defer gz.Close()
+
+	contents := make(map[string][]byte)
+	var manifest []archivedFile
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("archival: read archive failed: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("archival: read %s failed: %w", hdr.Name, err)
+		}
+		if hdr.Name == manifestName {
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("archival: decode manifest failed: %w", err)
+			}
+			continue
+		}
+		contents[hdr.Name] = data
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("archival: archive is missing %s", manifestName)
+	}
+
+	files := make([]models.File, 0, len(manifest))
+	for _, entry := range manifest {
+		content := contents[path.Join("files", entry.Path)]
+		size := int64(len(content))
+		if entry.IsBinary {
+			size = entry.Size
+		}
+		files = append(files, models.File{
+			ProjectID:  projectID,
+			Path:       entry.Path,
+			Name:       entry.Name,
+			Type:       entry.Type,
+			MimeType:   entry.MimeType,
+			Content:    string(content),
+			Size:       size,
+			Hash:       entry.Hash,
+			IsBinary:   entry.IsBinary,
+			Version:    entry.Version,
+			LastEditBy: entry.LastEditBy,
+		})
+	}
+	return files, nil
+}
@@ -0,0 +1,124 @@
+package archival
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupArchivalTest(t *testing.T) (*Service, *gorm.DB, storage.Provider, uint) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store, err := storage.NewLocalProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("local provider: %v", err)
+	}
+	name := strings.ToLower(t.Name())
+	user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return NewService(db, store), db, store, user.ID
+}
+
+func TestArchiveAndUnarchiveRoundTripsFiles(t *testing.T) {
+	svc, db, store, userID := setupArchivalTest(t)
+	ctx := context.Background()
+
+	project := models.Project{Name: "app", Language: "typescript", OwnerID: userID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	seed := []models.File{
+		{ProjectID: project.ID, Path: "src", Name: "src", Type: "directory"},
+		{ProjectID: project.ID, Path: "src/index.ts", Name: "index.ts", Type: "file", MimeType: "text/typescript", Content: "console.log('hi')", Version: 3},
+		{ProjectID: project.ID, Path: "README.md", Name: "README.md", Type: "file", Content: strings.Repeat("# docs\n", 200)},
+	}
+	for i := range seed {
+		if err := db.Create(&seed[i]).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+
+	result, err := svc.Archive(ctx, userID, project.ID)
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if result.Files != 3 || result.ArchiveSizeBytes <= 0 || result.ArchiveSizeBytes >= result.OriginalBytes {
+		t.Fatalf("unexpected archive result: %+v", result)
+	}
+
+	var archived models.Project
+	db.First(&archived, project.ID)
+	if !archived.IsArchived || archived.ArchivedAt == nil || archived.ArchiveKey == "" {
+		t.Fatalf("project not marked archived: %+v", archived)
+	}
+	var remaining int64
+	db.Unscoped().Model(&models.File{}).Where("project_id = ?", project.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("expected files removed from the database, %d left", remaining)
+	}
+	archiveKey := archived.ArchiveKey
+	if exists, _ := store.Exists(ctx, archiveKey); !exists {
+		t.Fatal("expected archive object in storage")
+	}
+
+	if _, err := svc.Archive(ctx, userID, project.ID); !errors.Is(err, ErrAlreadyArchived) {
+		t.Fatalf("expected ErrAlreadyArchived, got %v", err)
+	}
+
+	if _, err := svc.Unarchive(ctx, userID, project.ID); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+	var restored []models.File
+	db.Where("project_id = ?", project.ID).Order("path").Find(&restored)
+	if len(restored) != 3 {
+		t.Fatalf("expected 3 restored files, got %d", len(restored))
+	}
+	byPath := map[string]models.File{}
+	for _, f := range restored {
+		byPath[f.Path] = f
+	}
+	index := byPath["src/index.ts"]
+	if index.Content != "console.log('hi')" || index.MimeType != "text/typescript" || index.Version != 3 {
+		t.Fatalf("file metadata not restored: %+v", index)
+	}
+	if byPath["src"].Type != "directory" {
+		t.Fatalf("directory entry not restored: %+v", byPath["src"])
+	}
+
+	db.First(&archived, project.ID)
+	if archived.IsArchived || archived.ArchiveKey != "" {
+		t.Fatalf("project still archived after unarchive: %+v", archived)
+	}
+	if exists, _ := store.Exists(ctx, archiveKey); exists {
+		t.Fatal("expected archive object deleted after unarchive")
+	}
+}
+
+func TestArchiveRejectsOtherUsersProject(t *testing.T) {
+	svc, db, _, userID := setupArchivalTest(t)
+	project := models.Project{Name: "app", Language: "go", OwnerID: userID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	if _, err := svc.Archive(context.Background(), userID+1, project.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := svc.Unarchive(context.Background(), userID, project.ID); !errors.Is(err, ErrNotArchived) {
+		t.Fatalf("expected ErrNotArchived, got %v", err)
+	}
+}
@@ -117,6 +117,7 @@ type JWTClaims struct {
 	HasUnlimitedCredits bool   `json:"has_unlimited_credits"`
 	BypassBilling       bool   `json:"bypass_billing"`
 	BypassRateLimits    bool   `json:"bypass_rate_limits"`
+	SessionID           string `json:"sid,omitempty"` // Refresh token family; empty without a database
 	jwt.RegisteredClaims
 }
 
@@ -149,6 +150,8 @@ type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password" binding:"required"`
 	Email    string `json:"email"`
+	// Response from the CAPTCHA widget, required after suspicious attempts
+	CaptchaToken string `json:"captcha_token"`
 }
 
 // RegisterRequest represents a registration request
@@ -158,6 +161,7 @@ type RegisterRequest struct {
 	Password         string `json:"password" binding:"required,min=8"`
 	FullName         string `json:"full_name" binding:"max=100"`
 	AcceptLegalTerms bool   `json:"accept_legal_terms"`
+	ReferralCode     string `json:"referral_code,omitempty" binding:"max=16"`
 	AcceptanceIP     string `json:"-"`
 	AcceptanceAgent  string `json:"-"`
 }
@@ -257,6 +261,16 @@ func (a *AuthService) GenerateTokensWithMetadata(user *models.User, metadata *Re
 	accessExpiresAt := now.Add(a.tokenExpiry)
 	refreshExpiresAt := now.Add(a.refreshExpiry)
 
+	// The refresh token family is the session; access tokens carry it so
+	// revoking the session also rejects them
+	familyID := ""
+	if a.db != nil {
+		familyID = generateUUID()
+		if metadata != nil && metadata.FamilyID != "" {
+			familyID = metadata.FamilyID
+		}
+	}
+
 	// Create access token claims
 	accessClaims := &JWTClaims{
 		UserID:              user.ID,
@@ -270,6 +284,7 @@ func (a *AuthService) GenerateTokensWithMetadata(user *models.User, metadata *Re
 		HasUnlimitedCredits: user.HasUnlimitedCredits,
 		BypassBilling:       user.BypassBilling,
 		BypassRateLimits:    user.BypassRateLimits,
+		SessionID:           familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -301,11 +316,6 @@ func (a *AuthService) GenerateTokensWithMetadata(user *models.User, metadata *Re
 		warnRefreshTokenNoDB()
 	}
 	if a.db != nil {
-		familyID := generateUUID()
-		if metadata != nil && metadata.FamilyID != "" {
-			familyID = metadata.FamilyID
-		}
-
 		tokenHash := hashToken(refreshTokenString)
 
 		refreshTokenRecord := &models.RefreshToken{
@@ -324,6 +334,7 @@ func (a *AuthService) GenerateTokensWithMetadata(user *models.User, metadata *Re
 			refreshTokenRecord.UserAgent = metadata.UserAgent
 			refreshTokenRecord.DeviceID = metadata.DeviceID
 		}
+		refreshTokenRecord.LastActiveAt = &now
 
 		if err := a.db.Create(refreshTokenRecord).Error; err != nil {
 			return nil, fmt.Errorf("failed to store refresh token: %w", err)
@@ -389,6 +400,9 @@ func (a *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	if tokenBlacklist != nil && tokenBlacklist.IsBlacklisted(revocationIdentifierForToken(tokenString, claims.RegisteredClaims)) {
 		return nil, ErrTokenBlacklisted
 	}
+	if claims.SessionID != "" && tokenBlacklist != nil && tokenBlacklist.IsBlacklisted(sessionRevocationIdentifier(claims.SessionID)) {
+		return nil, ErrTokenBlacklisted
+	}
 
 	return claims, nil
 }
@@ -559,6 +573,7 @@ func (a *AuthService) RotateRefreshToken(refreshToken string, metadata *RefreshT
 			// Log the error but continue with the security response
 			fmt.Printf("Failed to revoke token family %s: %v\n", storedToken.FamilyID, err)
 		}
+		a.revokeSessionAccessTokens(storedToken.FamilyID)
 		return nil, ErrTokenFamilyCompromised
 	}
 
@@ -592,18 +607,23 @@ func (a *AuthService) RotateRefreshToken(refreshToken string, metadata *RefreshT
 	}
 
 	// Generate new tokens with the same family ID (for tracking)
+	// Preserve original metadata unless the request supplies newer values
 	newMetadata := &RefreshTokenMetadata{
-		FamilyID: storedToken.FamilyID,
+		FamilyID:  storedToken.FamilyID,
+		IPAddress: storedToken.IPAddress,
+		UserAgent: storedToken.UserAgent,
+		DeviceID:  storedToken.DeviceID,
 	}
 	if metadata != nil {
-		newMetadata.IPAddress = metadata.IPAddress
-		newMetadata.UserAgent = metadata.UserAgent
-		newMetadata.DeviceID = metadata.DeviceID
-	} else {
-		// Preserve original metadata
-		newMetadata.IPAddress = storedToken.IPAddress
-		newMetadata.UserAgent = storedToken.UserAgent
-		newMetadata.DeviceID = storedToken.DeviceID
+		if metadata.IPAddress != "" {
+			newMetadata.IPAddress = metadata.IPAddress
+		}
+		if metadata.UserAgent != "" {
+			newMetadata.UserAgent = metadata.UserAgent
+		}
+		if metadata.DeviceID != "" {
+			newMetadata.DeviceID = metadata.DeviceID
+		}
 	}
 
 	return a.GenerateTokensWithMetadata(&user, newMetadata)
@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"apex-build/internal/cache"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account temporarily locked after repeated failed logins")
+	ErrIPBlocked       = errors.New("too many failed logins from this address")
+	ErrCaptchaRequired = errors.New("captcha verification required")
+	ErrCaptchaFailed   = errors.New("captcha verification failed")
+)
+
+// Security event types emitted by LoginGuard
+const (
+	SecurityEventLoginFailed   = "login_failed"
+	SecurityEventAccountLocked = "account_locked"
+	SecurityEventIPSuspicious  = "ip_suspicious"
+	SecurityEventIPBlocked     = "ip_blocked"
+	SecurityEventCaptchaFailed = "captcha_failed"
+)
+
+// LoginGuardConfig tunes brute-force protection
+type LoginGuardConfig struct {
+	// Failed logins allowed on an account before it locks
+	FreeAttempts int
+	// First lockout; each further failure doubles it up to MaxLockout
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Account failures that trigger a CAPTCHA challenge before locking
+	CaptchaAfter int
+	// Account failure counts reset after this long without a failure
+	AccountWindow time.Duration
+
+	// IP reputation: failures per address within IPWindow
+	IPWindow           time.Duration
+	IPSuspiciousAfter  int // CAPTCHA required from this address
+	IPBlockAfter       int // Logins from this address rejected
+	IPSuccessDiscounts int // Failures forgiven per successful login
+}
+
+// DefaultLoginGuardConfig returns the production defaults
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		FreeAttempts:       5,
+		BaseLockout:        time.Minute,
+		MaxLockout:         24 * time.Hour,
+		CaptchaAfter:       3,
+		AccountWindow:      24 * time.Hour,
+		IPWindow:           time.Hour,
+		IPSuspiciousAfter:  10,
+		IPBlockAfter:       50,
+		IPSuccessDiscounts: 3,
+	}
+}
+
+// SecurityEvent describes a suspicious login event
+type SecurityEvent struct {
+	Type        string
+	UserID      *uint // Set when the identifier matches an account
+	Identifier  string
+	IPAddress   string
+	UserAgent   string
+	Failures    int
+	LockedUntil time.Time
+}
+
+// LoginAttempt identifies a login attempt
+type LoginAttempt struct {
+	// Account key: "user:<id>" when the login names an account, otherwise
+	// the username or email as typed, so either spelling counts together
+	Identifier   string
+	IPAddress    string
+	UserAgent    string
+	CaptchaToken string
+}
+
+// LoginDenial explains why an attempt was refused
+type LoginDenial struct {
+	Err             error
+	RetryAfter      time.Duration
+	CaptchaRequired bool
+}
+
+type accountAttempts struct {
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+type ipReputation struct {
+	Failures int  `json:"failures"`
+	Flagged  bool `json:"flagged"`
+	Blocked  bool `json:"blocked"`
+}
+
+// LoginGuard applies progressive per-account lockout, IP reputation and
+// CAPTCHA challenges to password logins. State lives in the shared cache so
+// every instance sees the same counters.
+type LoginGuard struct {
+	cache   *cache.RedisCache
+	config  LoginGuardConfig
+	captcha CaptchaVerifier
+	events  []func(SecurityEvent)
+	now     func() time.Time
+}
+
+// NewLoginGuard creates a login guard using REDIS_URL when set
+func NewLoginGuard(config LoginGuardConfig) *LoginGuard {
+	cacheConfig := cache.DefaultCacheConfig()
+	cacheConfig.DefaultTTL = config.AccountWindow
+
+	var guardCache *cache.RedisCache
+	if redisURL := strings.TrimSpace(os.Getenv("REDIS_URL")); redisURL != "" {
+		guardCache = cache.NewRedisCacheFromURL(redisURL, cacheConfig)
+	} else {
+		guardCache = cache.NewRedisCache(cacheConfig)
+	}
+	return &LoginGuard{cache: guardCache, config: config, now: time.Now}
+}
+
+// SetCaptchaVerifier enables CAPTCHA challenges on suspicious attempts.
+// Without one, suspicious attempts fall through to lockout.
+func (g *LoginGuard) SetCaptchaVerifier(verifier CaptchaVerifier) {
+	g.captcha = verifier
+}
+
+// CaptchaVerifier returns the configured verifier, if any
+func (g *LoginGuard) CaptchaVerifier() CaptchaVerifier {
+	return g.captcha
+}
+
+// OnSecurityEvent registers a handler for security events
+func (g *LoginGuard) OnSecurityEvent(handler func(SecurityEvent)) {
+	g.events = append(g.events, handler)
+}
+
+func (g *LoginGuard) emit(event SecurityEvent) {
+	for _, handler := range g.events {
+		handler(event)
+	}
+}
+
+func accountGuardKey(identifier string) string {
+	return "auth:guard:account:" + hashToken(strings.ToLower(strings.TrimSpace(identifier)))
+}
+
+func ipGuardKey(ip string) string {
+	return "auth:guard:ip:" + ip
+}
+
+func (g *LoginGuard) account(ctx context.Context, identifier string) accountAttempts {
+	var state accountAttempts
+	_ = g.cache.GetJSON(ctx, accountGuardKey(identifier), &state)
+	return state
+}
+
+func (g *LoginGuard) ip(ctx context.Context, ip string) ipReputation {
+	var state ipReputation
+	if ip != "" {
+		_ = g.cache.GetJSON(ctx, ipGuardKey(ip), &state)
+	}
+	return state
+}
+
+// Check decides whether a login attempt may proceed to password
+// verification. A nil result allows it.
+func (g *LoginGuard) Check(ctx context.Context, attempt LoginAttempt) *LoginDenial {
+	now := g.now()
+	reputation := g.ip(ctx, attempt.IPAddress)
+	if reputation.Blocked {
+		return &LoginDenial{Err: ErrIPBlocked, RetryAfter: g.config.IPWindow}
+	}
+
+	account := g.account(ctx, attempt.Identifier)
+	if now.Before(account.LockedUntil) {
+		return &LoginDenial{Err: ErrAccountLocked, RetryAfter: account.LockedUntil.Sub(now).Round(time.Second)}
+	}
+
+	suspicious := reputation.Flagged || (g.config.CaptchaAfter > 0 && account.Failures >= g.config.CaptchaAfter)
+	if !suspicious || g.captcha == nil {
+		return nil
+	}
+	if strings.TrimSpace(attempt.CaptchaToken) == "" {
+		return &LoginDenial{Err: ErrCaptchaRequired, CaptchaRequired: true}
+	}
+	ok, err := g.captcha.Verify(ctx, attempt.CaptchaToken, attempt.IPAddress)
+	if err != nil {
+		// Fail open on provider outages; lockout still applies
+		log.Printf("[auth] captcha verification unavailable: %v", err)
+		return nil
+	}
+	if !ok {
+		g.emit(SecurityEvent{
+			Type:       SecurityEventCaptchaFailed,
+			Identifier: attempt.Identifier,
+			IPAddress:  attempt.IPAddress,
+			UserAgent:  attempt.UserAgent,
+			Failures:   account.Failures,
+		})
+		return &LoginDenial{Err: ErrCaptchaFailed, CaptchaRequired: true}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against the account and address,
+// locking the account progressively once free attempts run out
+func (g *LoginGuard) RecordFailure(ctx context.Context, attempt LoginAttempt, userID *uint) {
+	now := g.now()
+
+	account := g.account(ctx, attempt.Identifier)
+	account.Failures++
+	event := SecurityEvent{
+		Type:       SecurityEventLoginFailed,
+		UserID:     userID,
+		Identifier: attempt.Identifier,
+		IPAddress:  attempt.IPAddress,
+		UserAgent:  attempt.UserAgent,
+		Failures:   account.Failures,
+	}
+	if over := account.Failures - g.config.FreeAttempts; over >= 0 {
+		account.LockedUntil = now.Add(g.lockoutFor(over))
+		event.Type = SecurityEventAccountLocked
+		event.LockedUntil = account.LockedUntil
+	}
+	_ = g.cache.SetJSON(ctx, accountGuardKey(attempt.Identifier), account, g.config.AccountWindow)
+	g.emit(event)
+
+	if attempt.IPAddress == "" {
+		return
+	}
+	reputation := g.ip(ctx, attempt.IPAddress)
+	reputation.Failures++
+	ipEvent := ""
+	if !reputation.Blocked && g.config.IPBlockAfter > 0 && reputation.Failures >= g.config.IPBlockAfter {
+		reputation.Blocked = true
+		ipEvent = SecurityEventIPBlocked
+	} else if !reputation.Flagged && g.config.IPSuspiciousAfter > 0 && reputation.Failures >= g.config.IPSuspiciousAfter {
+		reputation.Flagged = true
+		ipEvent = SecurityEventIPSuspicious
+	}
+	_ = g.cache.SetJSON(ctx, ipGuardKey(attempt.IPAddress), reputation, g.config.IPWindow)
+	if ipEvent != "" {
+		g.emit(SecurityEvent{
+			Type:      ipEvent,
+			IPAddress: attempt.IPAddress,
+			UserAgent: attempt.UserAgent,
+			Failures:  reputation.Failures,
+		})
+	}
+}
+
+// RecordSuccess clears the account's failures and improves the address's
+// reputation
+func (g *LoginGuard) RecordSuccess(ctx context.Context, attempt LoginAttempt) {
+	_ = g.cache.Delete(ctx, accountGuardKey(attempt.Identifier))
+	if attempt.IPAddress == "" {
+		return
+	}
+	reputation := g.ip(ctx, attempt.IPAddress)
+	if reputation.Failures == 0 || reputation.Blocked {
+		return
+	}
+	reputation.Failures -= g.config.IPSuccessDiscounts
+	if reputation.Failures < 0 {
+		reputation.Failures = 0
+	}
+	if reputation.Failures < g.config.IPSuspiciousAfter {
+		reputation.Flagged = false
+	}
+	_ = g.cache.SetJSON(ctx, ipGuardKey(attempt.IPAddress), reputation, g.config.IPWindow)
+}
+
+func (g *LoginGuard) lockoutFor(over int) time.Duration {
+	if over > 30 {
+		return g.config.MaxLockout
+	}
+	lockout := time.Duration(float64(g.config.BaseLockout) * math.Pow(2, float64(over)))
+	if lockout > g.config.MaxLockout {
+		return g.config.MaxLockout
+	}
+	return lockout
+}
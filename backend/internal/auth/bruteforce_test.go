@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCaptcha struct{ valid string }
+
+func (stubCaptcha) Provider() string { return CaptchaTurnstile }
+func (stubCaptcha) SiteKey() string  { return "site-key" }
+func (s stubCaptcha) Verify(_ context.Context, token, _ string) (bool, error) {
+	return token == s.valid, nil
+}
+
+func newTestLoginGuard(t *testing.T) (*LoginGuard, *time.Time, *[]SecurityEvent) {
+	t.Helper()
+	t.Setenv("REDIS_URL", "")
+	guard := NewLoginGuard(DefaultLoginGuardConfig())
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	var events []SecurityEvent
+	guard.OnSecurityEvent(func(event SecurityEvent) { events = append(events, event) })
+	return guard, &now, &events
+}
+
+func TestLoginGuardProgressiveLockout(t *testing.T) {
+	guard, now, events := newTestLoginGuard(t)
+	ctx := context.Background()
+	userID := uint(9)
+	attempt := LoginAttempt{Identifier: "user:9", IPAddress: "203.0.113.5"}
+
+	for i := 0; i < 4; i++ {
+		require.Nil(t, guard.Check(ctx, attempt))
+		guard.RecordFailure(ctx, attempt, &userID)
+	}
+	require.Nil(t, guard.Check(ctx, attempt), "the fifth attempt is still allowed")
+	guard.RecordFailure(ctx, attempt, &userID)
+
+	denial := guard.Check(ctx, attempt)
+	require.NotNil(t, denial)
+	assert.ErrorIs(t, denial.Err, ErrAccountLocked)
+	assert.Equal(t, time.Minute, denial.RetryAfter)
+	last := (*events)[len(*events)-1]
+	assert.Equal(t, SecurityEventAccountLocked, last.Type)
+	assert.Equal(t, &userID, last.UserID)
+
+	// The next failure after the lock expires doubles it
+	*now = now.Add(time.Minute)
+	require.Nil(t, guard.Check(ctx, attempt))
+	guard.RecordFailure(ctx, attempt, &userID)
+	denial = guard.Check(ctx, attempt)
+	require.NotNil(t, denial)
+	assert.Equal(t, 2*time.Minute, denial.RetryAfter)
+
+	// Success clears the account
+	*now = now.Add(2 * time.Minute)
+	guard.RecordSuccess(ctx, attempt)
+	assert.Nil(t, guard.Check(ctx, attempt))
+}
+
+func TestLoginGuardCaptchaAndIPReputation(t *testing.T) {
+	guard, _, events := newTestLoginGuard(t)
+	guard.SetCaptchaVerifier(stubCaptcha{valid: "solved"})
+	ctx := context.Background()
+
+	attempt := LoginAttempt{Identifier: "victim@example.com", IPAddress: "198.51.100.7"}
+	for i := 0; i < 3; i++ {
+		guard.RecordFailure(ctx, attempt, nil)
+	}
+	denial := guard.Check(ctx, attempt)
+	require.NotNil(t, denial)
+	assert.ErrorIs(t, denial.Err, ErrCaptchaRequired)
+
+	attempt.CaptchaToken = "wrong"
+	denial = guard.Check(ctx, attempt)
+	require.NotNil(t, denial)
+	assert.ErrorIs(t, denial.Err, ErrCaptchaFailed)
+	assert.Equal(t, SecurityEventCaptchaFailed, (*events)[len(*events)-1].Type)
+
+	attempt.CaptchaToken = "solved"
+	assert.Nil(t, guard.Check(ctx, attempt))
+
+	// Spraying many accounts from one address flags, then blocks it
+	for i := 0; i < 47; i++ {
+		guard.RecordFailure(ctx, LoginAttempt{Identifier: "spray" + string(rune('a'+i%26)) + string(rune('a'+i/26)), IPAddress: attempt.IPAddress}, nil)
+	}
+	fresh := LoginAttempt{Identifier: "someone-else", IPAddress: attempt.IPAddress}
+	denial = guard.Check(ctx, fresh)
+	require.NotNil(t, denial)
+	assert.ErrorIs(t, denial.Err, ErrIPBlocked)
+
+	var types []string
+	for _, event := range *events {
+		if event.Type == SecurityEventIPSuspicious || event.Type == SecurityEventIPBlocked {
+			types = append(types, event.Type)
+		}
+	}
+	assert.Equal(t, []string{SecurityEventIPSuspicious, SecurityEventIPBlocked}, types)
+	assert.Nil(t, guard.Check(ctx, LoginAttempt{Identifier: "someone-else", IPAddress: "192.0.2.1"}))
+}
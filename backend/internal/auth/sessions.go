@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrSessionNotFound is returned for sessions that do not exist or belong
+// to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval limits how often a session's last activity is written
+const sessionTouchInterval = time.Minute
+
+var sessionTouches = &sessionTouchLimiter{last: make(map[string]time.Time)}
+
+// sessionTouchLimiter remembers when each session's activity was last
+// written. Entries older than sessionTouchInterval no longer limit anything,
+// so they are swept once an interval and the map only holds sessions active
+// in the last couple of minutes.
+type sessionTouchLimiter struct {
+	mu    sync.Mutex
+	last  map[string]time.Time // session ID -> time of the last write
+	swept time.Time
+}
+
+// allow reports whether the session's activity should be written at now,
+// and records the write when it should
+func (l *sessionTouchLimiter) allow(sessionID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) >= sessionTouchInterval {
+		for id, last := range l.last {
+			if now.Sub(last) >= sessionTouchInterval {
+				delete(l.last, id)
+			}
+		}
+		l.swept = now
+	}
+	if last, ok := l.last[sessionID]; ok && now.Sub(last) < sessionTouchInterval {
+		return false
+	}
+	l.last[sessionID] = now
+	return true
+}
+
+func (l *sessionTouchLimiter) forget(sessionID string) {
+	l.mu.Lock()
+	delete(l.last, sessionID)
+	l.mu.Unlock()
+}
+
+// Session is a signed-in device: one refresh token family, from login
+// until logout, revocation or expiry
+type Session struct {
+	ID           string    `json:"id"`
+	Device       string    `json:"device"`
+	DeviceID     string    `json:"device_id,omitempty"`
+	IPAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Current      bool      `json:"current"`
+}
+
+// DeviceIDHeader lets clients name a stable device identifier for sessions
+const DeviceIDHeader = "X-Apex-Device-ID"
+
+// RequestMetadata describes the device behind a request, for the session
+// its tokens belong to
+func RequestMetadata(c *gin.Context) *RefreshTokenMetadata {
+	deviceID := strings.TrimSpace(c.GetHeader(DeviceIDHeader))
+	if len(deviceID) > 128 {
+		deviceID = deviceID[:128]
+	}
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	return &RefreshTokenMetadata{
+		IPAddress: c.ClientIP(),
+		UserAgent: userAgent,
+		DeviceID:  deviceID,
+	}
+}
+
+func sessionRevocationIdentifier(sessionID string) string {
+	return "sid:" + sessionID
+}
+
+// RefreshSession rotates a refresh token within its session, recording the
+// device presenting it. A reused token revokes the whole session.
+func (a *AuthService) RefreshSession(refreshToken string, metadata *RefreshTokenMetadata) (*TokenPair, error) {
+	if a.db == nil {
+		return a.RefreshTokens(refreshToken, nil)
+	}
+	return a.RotateRefreshToken(refreshToken, metadata)
+}
+
+// ListSessions returns a user's active sessions, most recently active first.
+// currentSessionID marks the caller's own session.
+func (a *AuthService) ListSessions(userID uint, currentSessionID string) ([]Session, error) {
+	tokens, err := a.GetUserRefreshTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return []Session{}, nil
+	}
+
+	familyIDs := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		familyIDs = append(familyIDs, token.FamilyID)
+	}
+	var history []models.RefreshToken
+	if err := a.db.Select("family_id", "issued_at").
+		Where("family_id IN ?", familyIDs).
+		Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to get session start times: %w", err)
+	}
+	started := make(map[string]time.Time, len(familyIDs))
+	for _, token := range history {
+		if start, ok := started[token.FamilyID]; !ok || token.IssuedAt.Before(start) {
+			started[token.FamilyID] = token.IssuedAt
+		}
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		// A family has one live token; guard against races during rotation
+		if seen[token.FamilyID] {
+			continue
+		}
+		seen[token.FamilyID] = true
+
+		session := Session{
+			ID:           token.FamilyID,
+			Device:       DescribeDevice(token.UserAgent),
+			DeviceID:     token.DeviceID,
+			IPAddress:    token.IPAddress,
+			UserAgent:    token.UserAgent,
+			CreatedAt:    token.IssuedAt,
+			LastActiveAt: token.IssuedAt,
+			ExpiresAt:    token.ExpiresAt,
+			Current:      token.FamilyID == currentSessionID,
+		}
+		if start, ok := started[token.FamilyID]; ok {
+			session.CreatedAt = start
+		}
+		if token.LastActiveAt != nil && token.LastActiveAt.After(session.LastActiveAt) {
+			session.LastActiveAt = *token.LastActiveAt
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastActiveAt.After(sessions[j].LastActiveAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession signs a user out of one session. Its refresh token stops
+// working immediately and so do access tokens already issued to it.
+func (a *AuthService) RevokeSession(userID uint, sessionID string) error {
+	if a.db == nil {
+		return errors.New("database not configured for refresh token management")
+	}
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return ErrSessionNotFound
+	}
+
+	var count int64
+	if err := a.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id = ?", userID, sessionID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to look up session: %w", err)
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+
+	if err := a.RevokeTokenFamily(sessionID); err != nil {
+		return err
+	}
+	a.revokeSessionAccessTokens(sessionID)
+	return nil
+}
+
+// RevokeAllSessions signs a user out everywhere except exceptSessionID,
+// which may be empty. It returns the number of sessions revoked.
+func (a *AuthService) RevokeAllSessions(userID uint, exceptSessionID string) (int, error) {
+	if a.db == nil {
+		return 0, errors.New("database not configured for refresh token management")
+	}
+
+	var familyIDs []string
+	if err := a.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked = ? AND expires_at > ?", userID, false, time.Now()).
+		Distinct("family_id").
+		Pluck("family_id", &familyIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	revoked := 0
+	for _, familyID := range familyIDs {
+		if familyID == exceptSessionID {
+			continue
+		}
+		if err := a.RevokeTokenFamily(familyID); err != nil {
+			return revoked, err
+		}
+		a.revokeSessionAccessTokens(familyID)
+		revoked++
+	}
+	return revoked, nil
+}
+
+// revokeSessionAccessTokens rejects access tokens issued to a session until
+// the longest of them would have expired anyway
+func (a *AuthService) revokeSessionAccessTokens(sessionID string) {
+	if tokenBlacklist == nil {
+		initTokenBlacklist()
+	}
+	_ = tokenBlacklist.Add(sessionRevocationIdentifier(sessionID), time.Now().Add(a.tokenExpiry))
+	sessionTouches.forget(sessionID)
+}
+
+// TouchSession records activity on a session, at most once a minute
+func (a *AuthService) TouchSession(sessionID, ipAddress string) {
+	if a.db == nil || sessionID == "" {
+		return
+	}
+	now := time.Now()
+	if !sessionTouches.allow(sessionID, now) {
+		return
+	}
+
+	updates := map[string]interface{}{"last_active_at": now}
+	if ipAddress != "" {
+		updates["ip_address"] = ipAddress
+	}
+	a.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND used = ? AND revoked = ?", sessionID, false, false).
+		Updates(updates)
+}
+
+// DescribeDevice turns a user agent into a short label such as
+// "Chrome on macOS"
+func DescribeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	case strings.Contains(ua, "apex-cli") || strings.Contains(ua, "apex/"):
+		browser = "APEX CLI"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "cros"):
+		platform = "ChromeOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
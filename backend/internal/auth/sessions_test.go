@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newSessionTestService(t *testing.T) (*AuthService, *models.User) {
+	t.Helper()
+	gormDB, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, gormDB.AutoMigrate(&models.User{}, &models.RefreshToken{}))
+
+	authService := NewAuthService("test-session-secret")
+	authService.SetDB(gormDB)
+	user := &models.User{Username: "sessions", Email: "sessions@example.com", PasswordHash: "hash", IsActive: true}
+	require.NoError(t, gormDB.Create(user).Error)
+	return authService, user
+}
+
+func TestSessionsListAndRevoke(t *testing.T) {
+	authService, user := newSessionTestService(t)
+
+	laptop, err := authService.GenerateTokensWithMetadata(user, &RefreshTokenMetadata{
+		IPAddress: "10.0.0.1",
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/537.36 Chrome/120.0 Safari/537.36",
+	})
+	require.NoError(t, err)
+	phone, err := authService.GenerateTokensWithMetadata(user, &RefreshTokenMetadata{
+		IPAddress: "10.0.0.2",
+		UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1",
+	})
+	require.NoError(t, err)
+
+	laptopClaims, err := authService.ValidateToken(laptop.AccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, laptopClaims.SessionID)
+
+	// Rotation keeps the session and records the new address
+	laptop, err = authService.RefreshSession(laptop.RefreshToken, &RefreshTokenMetadata{IPAddress: "10.0.0.9"})
+	require.NoError(t, err)
+	rotatedClaims, err := authService.ValidateToken(laptop.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, laptopClaims.SessionID, rotatedClaims.SessionID)
+
+	sessions, err := authService.ListSessions(user.ID, laptopClaims.SessionID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	devices := map[string]Session{}
+	for _, session := range sessions {
+		devices[session.Device] = session
+	}
+	assert.True(t, devices["Chrome on macOS"].Current)
+	assert.Equal(t, "10.0.0.9", devices["Chrome on macOS"].IPAddress)
+	assert.False(t, devices["Safari on iOS"].Current)
+
+	phoneClaims, err := authService.ValidateToken(phone.AccessToken)
+	require.NoError(t, err)
+	assert.ErrorIs(t, authService.RevokeSession(user.ID+1, phoneClaims.SessionID), ErrSessionNotFound)
+	require.NoError(t, authService.RevokeSession(user.ID, phoneClaims.SessionID))
+
+	_, err = authService.ValidateToken(phone.AccessToken)
+	assert.ErrorIs(t, err, ErrTokenBlacklisted, "access tokens of a revoked session are rejected")
+	_, err = authService.RefreshSession(phone.RefreshToken, nil)
+	assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+	_, err = authService.ValidateToken(laptop.AccessToken)
+	assert.NoError(t, err, "other sessions stay signed in")
+
+	revoked, err := authService.RevokeAllSessions(user.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, revoked)
+	_, err = authService.ValidateToken(laptop.AccessToken)
+	assert.ErrorIs(t, err, ErrTokenBlacklisted)
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	authService, user := newSessionTestService(t)
+
+	first, err := authService.GenerateTokensWithMetadata(user, &RefreshTokenMetadata{UserAgent: "curl/8.0"})
+	require.NoError(t, err)
+	second, err := authService.RefreshSession(first.RefreshToken, nil)
+	require.NoError(t, err)
+
+	_, err = authService.RefreshSession(first.RefreshToken, nil)
+	assert.ErrorIs(t, err, ErrTokenFamilyCompromised)
+	_, err = authService.ValidateToken(second.AccessToken)
+	assert.ErrorIs(t, err, ErrTokenBlacklisted, "a replayed refresh token signs the session out")
+	_, err = authService.RefreshSession(second.RefreshToken, nil)
+	assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+}
+
+func TestSessionTouchLimiterSweepsIdleSessions(t *testing.T) {
+	limiter := &sessionTouchLimiter{last: make(map[string]time.Time)}
+	start := time.Now()
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, limiter.allow(fmt.Sprintf("idle-%d", i), start))
+	}
+	assert.True(t, limiter.allow("active", start))
+	assert.False(t, limiter.allow("active", start.Add(30*time.Second)), "a second touch within the interval is skipped")
+	assert.Len(t, limiter.last, 101)
+
+	// Once the interval passes, sessions that went quiet are dropped
+	later := start.Add(sessionTouchInterval + time.Second)
+	assert.True(t, limiter.allow("active", later))
+	assert.Len(t, limiter.last, 1)
+
+	limiter.forget("active")
+	assert.Empty(t, limiter.last)
+}
@@ -0,0 +1,529 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"apex-build/internal/notifications"
+	"apex-build/internal/spend"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Anomaly kinds detected by AnomalyDetector.
+const (
+	AnomalyTokenSpike = "token_spike" // hourly token usage far above the user's baseline
+	AnomalyRetryLoop  = "retry_loop"  // one agent hammering the same target inside a build
+	AnomalyBudgetHog  = "budget_hog"  // one build consuming most of the daily budget
+)
+
+// Actions taken when an anomaly is detected.
+const (
+	AnomalyActionBuildPaused = "build_paused"
+	AnomalyActionKeyPaused   = "key_paused"
+	AnomalyActionNotified    = "notified"
+)
+
+// DefaultAnomalyInterval is how often the detector scans recent spend.
+const DefaultAnomalyInterval = 2 * time.Minute
+
+// ErrAnomalyNotFound is returned when an anomaly does not exist for the user.
+var ErrAnomalyNotFound = errors.New("spend anomaly not found")
+
+// SpendAnomaly records a detected abnormal spend pattern and the action taken.
+type SpendAnomaly struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	UserID        uint       `gorm:"not null;index:idx_spend_anomaly_user_kind,priority:1" json:"user_id"`
+	Kind          string     `gorm:"not null;size:32;index:idx_spend_anomaly_user_kind,priority:2" json:"kind"`
+	BuildID       string     `gorm:"size:64;index" json:"build_id,omitempty"`
+	Provider      string     `gorm:"size:32" json:"provider,omitempty"`
+	Action        string     `gorm:"not null;size:32" json:"action"`
+	ObservedValue float64    `gorm:"type:numeric(14,6)" json:"observed_value"`
+	BaselineValue float64    `gorm:"type:numeric(14,6)" json:"baseline_value"`
+	Details       string     `gorm:"type:text" json:"details"`
+	PausedKeyIDs  []uint     `gorm:"serializer:json" json:"paused_key_ids,omitempty"` // BYOK keys this anomaly deactivated
+	OverriddenAt  *time.Time `json:"overridden_at,omitempty"`
+}
+
+func (SpendAnomaly) TableName() string { return "spend_anomalies" }
+
+// BuildPauser pauses and resumes live builds. main.go adapts agents.AgentManager
+// to this interface; budget cannot import agents directly.
+type BuildPauser interface {
+	PauseBuild(buildID, reason string) error
+	ResumeBuild(buildID, reason string) error
+}
+
+// AnomalyThresholds tunes the detectors.
+type AnomalyThresholds struct {
+	SpikeMultiplier    float64       // recent hour vs. hourly baseline
+	MinSpikeTokens     int64         // floor so small users never trip the spike detector
+	RetryWindow        time.Duration // window for retry loop detection
+	RetryLoopCalls     int64         // calls against one target within RetryWindow
+	BudgetHogShare     float64       // fraction of the daily budget one build may consume
+	MinBudgetHogUSD    float64       // floor when no daily cap is configured
+	DedupWindow        time.Duration // don't re-flag the same target within this window
+	DominantBuildShare float64       // share of spike tokens that pins the spike on one build
+	BaselineWindow     time.Duration // history used for the hourly token baseline
+}
+
+// DefaultAnomalyThresholds returns the production thresholds.
+func DefaultAnomalyThresholds() AnomalyThresholds {
+	return AnomalyThresholds{
+		SpikeMultiplier:    10,
+		MinSpikeTokens:     200_000,
+		RetryWindow:        15 * time.Minute,
+		RetryLoopCalls:     15,
+		BudgetHogShare:     0.8,
+		MinBudgetHogUSD:    5,
+		DedupWindow:        24 * time.Hour,
+		DominantBuildShare: 0.5,
+		BaselineWindow:     7 * 24 * time.Hour,
+	}
+}
+
+// AnomalyDetector scans spend_events for abnormal patterns, pauses the
+// offending build or BYOK key, and notifies the user with an override action.
+type AnomalyDetector struct {
+	db         *gorm.DB
+	builds     BuildPauser
+	notifier   *notifications.Service
+	thresholds AnomalyThresholds
+}
+
+// NewAnomalyDetector creates a detector with the default thresholds.
+func NewAnomalyDetector(db *gorm.DB, builds BuildPauser, notifier *notifications.Service) *AnomalyDetector {
+	return &AnomalyDetector{
+		db:         db,
+		builds:     builds,
+		notifier:   notifier,
+		thresholds: DefaultAnomalyThresholds(),
+	}
+}
+
+// SetThresholds overrides the detection thresholds.
+func (d *AnomalyDetector) SetThresholds(t AnomalyThresholds) {
+	d.thresholds = t
+}
+
+// Start runs the detector on a ticker until ctx is cancelled.
+func (d *AnomalyDetector) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultAnomalyInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := d.RunOnce(ctx, time.Now().UTC()); err != nil {
+					log.Printf("budget: spend anomaly scan failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// RunOnce runs every detector against spend recorded up to now and returns
+// the anomalies it flagged.
+func (d *AnomalyDetector) RunOnce(ctx context.Context, now time.Time) ([]SpendAnomaly, error) {
+	if d == nil || d.db == nil {
+		return nil, fmt.Errorf("budget: anomaly detector database unavailable")
+	}
+	var flagged []SpendAnomaly
+	for _, detect := range []func(context.Context, time.Time) ([]SpendAnomaly, error){
+		d.detectTokenSpikes,
+		d.detectRetryLoops,
+		d.detectBudgetHogs,
+	} {
+		found, err := detect(ctx, now)
+		if err != nil {
+			return flagged, err
+		}
+		flagged = append(flagged, found...)
+	}
+	return flagged, nil
+}
+
+func (d *AnomalyDetector) detectTokenSpikes(ctx context.Context, now time.Time) ([]SpendAnomaly, error) {
+	t := d.thresholds
+	hourAgo := now.Add(-time.Hour)
+	var recent []struct {
+		UserID uint
+		Tokens int64
+	}
+	if err := d.db.WithContext(ctx).Model(&spend.SpendEvent{}).
+		Select("user_id, COALESCE(SUM(input_tokens + output_tokens), 0) AS tokens").
+		Where("created_at >= ? AND created_at <= ?", hourAgo, now).
+		Group("user_id").
+		Having("COALESCE(SUM(input_tokens + output_tokens), 0) >= ?", t.MinSpikeTokens).
+		Scan(&recent).Error; err != nil {
+		return nil, fmt.Errorf("budget: token spike query failed: %w", err)
+	}
+
+	var flagged []SpendAnomaly
+	for _, row := range recent {
+		var baseline struct{ Tokens int64 }
+		if err := d.db.WithContext(ctx).Model(&spend.SpendEvent{}).
+			Select("COALESCE(SUM(input_tokens + output_tokens), 0) AS tokens").
+			Where("user_id = ? AND created_at >= ? AND created_at < ?", row.UserID, hourAgo.Add(-t.BaselineWindow), hourAgo).
+			Scan(&baseline).Error; err != nil {
+			return flagged, fmt.Errorf("budget: token baseline query failed: %w", err)
+		}
+		hourlyAvg := float64(baseline.Tokens) / t.BaselineWindow.Hours()
+		// New users have no history; treat the spike floor as their baseline
+		// so the multiplier still means something.
+		floor := float64(t.MinSpikeTokens) / t.SpikeMultiplier
+		if hourlyAvg < floor {
+			hourlyAvg = floor
+		}
+		if float64(row.Tokens) < t.SpikeMultiplier*hourlyAvg {
+			continue
+		}
+
+		anomaly := SpendAnomaly{
+			UserID:        row.UserID,
+			Kind:          AnomalyTokenSpike,
+			ObservedValue: float64(row.Tokens),
+			BaselineValue: hourlyAvg,
+			Details: fmt.Sprintf("%d tokens in the last hour, %.1fx the hourly baseline of %.0f",
+				row.Tokens, float64(row.Tokens)/hourlyAvg, hourlyAvg),
+		}
+
+		// Pin the spike on a build when one dominates, otherwise on a BYOK key.
+		var top struct {
+			BuildID  string
+			Provider string
+			IsBYOK   bool
+			Tokens   int64
+		}
+		if err := d.db.WithContext(ctx).Model(&spend.SpendEvent{}).
+			Select("build_id, provider, is_byok, COALESCE(SUM(input_tokens + output_tokens), 0) AS tokens").
+			Where("user_id = ? AND created_at >= ? AND created_at <= ?", row.UserID, hourAgo, now).
+			Group("build_id, provider, is_byok").
+			Order("tokens DESC").
+			Limit(1).
+			Scan(&top).Error; err != nil {
+			return flagged, fmt.Errorf("budget: token spike attribution failed: %w", err)
+		}
+		if top.BuildID != "" && float64(top.Tokens) >= t.DominantBuildShare*float64(row.Tokens) {
+			anomaly.BuildID = top.BuildID
+		} else if top.IsBYOK {
+			anomaly.Provider = top.Provider
+		}
+
+		if created, err := d.flag(ctx, now, &anomaly); err != nil {
+			return flagged, err
+		} else if created {
+			flagged = append(flagged, anomaly)
+		}
+	}
+	return flagged, nil
+}
+
+func (d *AnomalyDetector) detectRetryLoops(ctx context.Context, now time.Time) ([]SpendAnomaly, error) {
+	t := d.thresholds
+	var rows []struct {
+		UserID     uint
+		BuildID    string
+		AgentRole  string
+		TargetFile string
+		Calls      int64
+	}
+	// Repeated calls from the same agent against the same file, or a burst of
+	// failed calls, inside one build are the signature of a runaway retry loop.
+	if err := d.db.WithContext(ctx).Model(&spend.SpendEvent{}).
+		Select("user_id, build_id, agent_role, target_file, COUNT(*) AS calls").
+		Where("created_at >= ? AND created_at <= ? AND build_id <> ''", now.Add(-t.RetryWindow), now).
+		Where("target_file <> '' OR status <> ?", "success").
+		Group("user_id, build_id, agent_role, target_file").
+		Having("COUNT(*) >= ?", t.RetryLoopCalls).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("budget: retry loop query failed: %w", err)
+	}
+
+	var flagged []SpendAnomaly
+	for _, row := range rows {
+		target := row.TargetFile
+		if target == "" {
+			target = "failed requests"
+		}
+		anomaly := SpendAnomaly{
+			UserID:        row.UserID,
+			Kind:          AnomalyRetryLoop,
+			BuildID:       row.BuildID,
+			ObservedValue: float64(row.Calls),
+			BaselineValue: float64(t.RetryLoopCalls),
+			Details: fmt.Sprintf("%s agent made %d AI calls for %s in %s",
+				row.AgentRole, row.Calls, target, t.RetryWindow),
+		}
+		if created, err := d.flag(ctx, now, &anomaly); err != nil {
+			return flagged, err
+		} else if created {
+			flagged = append(flagged, anomaly)
+		}
+	}
+	return flagged, nil
+}
+
+func (d *AnomalyDetector) detectBudgetHogs(ctx context.Context, now time.Time) ([]SpendAnomaly, error) {
+	t := d.thresholds
+	dayKey := now.Format("2006-01-02")
+	var rows []struct {
+		UserID  uint
+		BuildID string
+		Cost    float64
+	}
+	if err := d.db.WithContext(ctx).Model(&spend.SpendEvent{}).
+		Select("user_id, build_id, COALESCE(SUM(billed_cost), 0) AS cost").
+		Where("day_key = ? AND build_id <> ''", dayKey).
+		Group("user_id, build_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("budget: budget hog query failed: %w", err)
+	}
+
+	var flagged []SpendAnomaly
+	for _, row := range rows {
+		var dailyCaps []BudgetCap
+		if err := d.db.WithContext(ctx).
+			Where("user_id = ? AND cap_type = ? AND project_id IS NULL AND is_active = ? AND deleted_at IS NULL", row.UserID, "daily", true).
+			Limit(1).
+			Find(&dailyCaps).Error; err != nil {
+			return flagged, fmt.Errorf("budget: daily cap lookup failed: %w", err)
+		}
+		budgetUSD := 0.0
+		if len(dailyCaps) > 0 {
+			budgetUSD = dailyCaps[0].LimitUSD
+		} else {
+			// Without a cap, measure against the day's total spend so a single
+			// build eating most of a large day still stands out.
+			var total struct{ Cost float64 }
+			if err := d.db.WithContext(ctx).Model(&spend.SpendEvent{}).
+				Select("COALESCE(SUM(billed_cost), 0) AS cost").
+				Where("user_id = ? AND day_key = ?", row.UserID, dayKey).
+				Scan(&total).Error; err != nil {
+				return flagged, fmt.Errorf("budget: daily total query failed: %w", err)
+			}
+			if total.Cost < t.MinBudgetHogUSD {
+				continue
+			}
+			budgetUSD = total.Cost
+		}
+		if budgetUSD <= 0 || row.Cost < t.BudgetHogShare*budgetUSD {
+			continue
+		}
+
+		anomaly := SpendAnomaly{
+			UserID:        row.UserID,
+			Kind:          AnomalyBudgetHog,
+			BuildID:       row.BuildID,
+			ObservedValue: row.Cost,
+			BaselineValue: budgetUSD,
+			Details: fmt.Sprintf("build spent $%.2f today, %.0f%% of the $%.2f daily budget",
+				row.Cost, 100*row.Cost/budgetUSD, budgetUSD),
+		}
+		if created, err := d.flag(ctx, now, &anomaly); err != nil {
+			return flagged, err
+		} else if created {
+			flagged = append(flagged, anomaly)
+		}
+	}
+	return flagged, nil
+}
+
+// flag records the anomaly unless the same target was already flagged inside
+// the dedup window, pauses the offender, and notifies the user.
+func (d *AnomalyDetector) flag(ctx context.Context, now time.Time, anomaly *SpendAnomaly) (bool, error) {
+	var existing int64
+	if err := d.db.WithContext(ctx).Model(&SpendAnomaly{}).
+		Where("user_id = ? AND kind = ? AND build_id = ? AND provider = ? AND created_at >= ?",
+			anomaly.UserID, anomaly.Kind, anomaly.BuildID, anomaly.Provider, now.Add(-d.thresholds.DedupWindow)).
+		Count(&existing).Error; err != nil {
+		return false, fmt.Errorf("budget: anomaly dedup query failed: %w", err)
+	}
+	if existing > 0 {
+		return false, nil
+	}
+
+	anomaly.Action = AnomalyActionNotified
+	switch {
+	case anomaly.BuildID != "" && d.builds != nil:
+		if err := d.builds.PauseBuild(anomaly.BuildID, "spend anomaly: "+anomaly.Kind); err != nil {
+			log.Printf("budget: could not pause build %s for %s: %v", anomaly.BuildID, anomaly.Kind, err)
+		} else {
+			anomaly.Action = AnomalyActionBuildPaused
+		}
+	case anomaly.Provider != "":
+		var keyIDs []uint
+		err := d.db.WithContext(ctx).Model(&models.UserAPIKey{}).
+			Where("user_id = ? AND provider = ? AND is_active = ?", anomaly.UserID, anomaly.Provider, true).
+			Pluck("id", &keyIDs).Error
+		if err == nil && len(keyIDs) > 0 {
+			err = d.db.WithContext(ctx).Model(&models.UserAPIKey{}).
+				Where("id IN ?", keyIDs).
+				Update("is_active", false).Error
+		}
+		if err != nil {
+			log.Printf("budget: could not pause %s key for user %d: %v", anomaly.Provider, anomaly.UserID, err)
+		} else if len(keyIDs) > 0 {
+			anomaly.Action = AnomalyActionKeyPaused
+			anomaly.PausedKeyIDs = keyIDs
+		}
+	}
+
+	anomaly.CreatedAt = now
+	if err := d.db.WithContext(ctx).Create(anomaly).Error; err != nil {
+		return false, fmt.Errorf("budget: record anomaly failed: %w", err)
+	}
+	d.notify(ctx, anomaly)
+	return true, nil
+}
+
+func (d *AnomalyDetector) notify(ctx context.Context, anomaly *SpendAnomaly) {
+	if d.notifier == nil {
+		return
+	}
+	title := "Unusual AI spend detected"
+	severity := notifications.SeverityWarning
+	switch anomaly.Action {
+	case AnomalyActionBuildPaused:
+		title = "Build paused: unusual AI spend"
+		severity = notifications.SeverityCritical
+	case AnomalyActionKeyPaused:
+		title = "API key paused: unusual AI spend"
+		severity = notifications.SeverityCritical
+	}
+	n := &notifications.Notification{
+		UserID:   anomaly.UserID,
+		Kind:     "spend_anomaly",
+		Severity: severity,
+		Title:    title,
+		Body:     anomaly.Details,
+		Metadata: map[string]any{
+			"anomaly_id": anomaly.ID,
+			"kind":       anomaly.Kind,
+			"action":     anomaly.Action,
+			"build_id":   anomaly.BuildID,
+			"provider":   anomaly.Provider,
+		},
+	}
+	if anomaly.Action != AnomalyActionNotified {
+		n.ActionLabel = "Override"
+		n.ActionMethod = "POST"
+		n.ActionURL = fmt.Sprintf("/api/v1/budget/anomalies/%d/override", anomaly.ID)
+	}
+	if err := d.notifier.Notify(ctx, n); err != nil {
+		log.Printf("budget: spend anomaly notification failed for user %d: %v", anomaly.UserID, err)
+	}
+
+	// Managers of the user's organizations get a copy without the override
+	// action; only the user can override their own anomaly.
+	for _, managerID := range d.orgManagers(ctx, anomaly.UserID) {
+		metadata := make(map[string]any, len(n.Metadata)+1)
+		for k, v := range n.Metadata {
+			metadata[k] = v
+		}
+		metadata["member_id"] = anomaly.UserID
+		if err := d.notifier.Notify(ctx, &notifications.Notification{
+			UserID:   managerID,
+			Kind:     n.Kind,
+			Severity: n.Severity,
+			Title:    n.Title,
+			Body:     n.Body,
+			Metadata: metadata,
+		}); err != nil {
+			log.Printf("budget: spend anomaly notification failed for org manager %d: %v", managerID, err)
+		}
+	}
+}
+
+// orgManagers returns the other active members who can manage an
+// organization the user belongs to.
+func (d *AnomalyDetector) orgManagers(ctx context.Context, userID uint) []uint {
+	db := d.db.WithContext(ctx)
+	if !db.Migrator().HasTable("organization_members") {
+		return nil
+	}
+	orgIDs := db.Table("organization_members").Select("organization_id").
+		Where("user_id = ? AND status = ? AND deleted_at IS NULL", userID, "active")
+	var managerIDs []uint
+	if err := db.Table("organization_members m").
+		Joins("JOIN role_permissions rp ON rp.role_id = m.role_id").
+		Joins("JOIN permissions p ON p.id = rp.permission_id").
+		Where("m.organization_id IN (?) AND m.user_id <> ? AND m.status = ? AND m.deleted_at IS NULL", orgIDs, userID, "active").
+		Where("p.resource = ? AND p.action = ?", "organization", "manage").
+		Distinct().
+		Pluck("m.user_id", &managerIDs).Error; err != nil {
+		log.Printf("budget: could not look up organization managers for user %d: %v", userID, err)
+		return nil
+	}
+	return managerIDs
+}
+
+// ListAnomalies returns the newest anomalies flagged for a user.
+func (d *AnomalyDetector) ListAnomalies(ctx context.Context, userID uint, limit int) ([]SpendAnomaly, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	var items []SpendAnomaly
+	if err := d.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("budget: list anomalies failed: %w", err)
+	}
+	return items, nil
+}
+
+// Override lets the user dismiss an anomaly: the paused build is resumed or
+// the keys it paused re-enabled. The dedup window keeps the same target from
+// being re-flagged immediately.
+func (d *AnomalyDetector) Override(ctx context.Context, userID, anomalyID uint) (*SpendAnomaly, error) {
+	var anomaly SpendAnomaly
+	err := d.db.WithContext(ctx).Where("id = ? AND user_id = ?", anomalyID, userID).First(&anomaly).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAnomalyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("budget: load anomaly failed: %w", err)
+	}
+	if anomaly.OverriddenAt != nil {
+		return &anomaly, nil
+	}
+
+	switch anomaly.Action {
+	case AnomalyActionBuildPaused:
+		if d.builds != nil {
+			if err := d.builds.ResumeBuild(anomaly.BuildID, "spend anomaly overridden by user"); err != nil {
+				// The build may have finished or been cancelled since; the
+				// override itself still stands.
+				log.Printf("budget: resume build %s after override: %v", anomaly.BuildID, err)
+			}
+		}
+	case AnomalyActionKeyPaused:
+		if len(anomaly.PausedKeyIDs) > 0 {
+			if err := d.db.WithContext(ctx).Model(&models.UserAPIKey{}).
+				Where("user_id = ? AND id IN ?", anomaly.UserID, anomaly.PausedKeyIDs).
+				Update("is_active", true).Error; err != nil {
+				return nil, fmt.Errorf("budget: re-enable key failed: %w", err)
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := d.db.WithContext(ctx).Model(&anomaly).Update("overridden_at", now).Error; err != nil {
+		return nil, fmt.Errorf("budget: mark anomaly overridden failed: %w", err)
+	}
+	anomaly.OverriddenAt = &now
+	return &anomaly, nil
+}
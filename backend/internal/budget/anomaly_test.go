@@ -0,0 +1,264 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/notifications"
+	"apex-build/internal/spend"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type fakeBuildPauser struct {
+	paused  []string
+	resumed []string
+}
+
+func (f *fakeBuildPauser) PauseBuild(buildID, reason string) error {
+	f.paused = append(f.paused, buildID)
+	return nil
+}
+
+func (f *fakeBuildPauser) ResumeBuild(buildID, reason string) error {
+	f.resumed = append(f.resumed, buildID)
+	return nil
+}
+
+func anomalyTestDetector(t *testing.T) (*AnomalyDetector, *gorm.DB, *fakeBuildPauser, *notifications.Service) {
+	t.Helper()
+	db := testDB(t)
+	if err := db.AutoMigrate(&SpendAnomaly{}, &notifications.Notification{}, &models.UserAPIKey{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	pauser := &fakeBuildPauser{}
+	notifier := notifications.NewService(db)
+	return NewAnomalyDetector(db, pauser, notifier), db, pauser, notifier
+}
+
+func seedAnomalyEvent(t *testing.T, db *gorm.DB, ev spend.SpendEvent, at time.Time) {
+	t.Helper()
+	ev.CreatedAt = at
+	ev.DayKey = at.Format("2006-01-02")
+	ev.MonthKey = at.Format("2006-01")
+	if ev.Provider == "" {
+		ev.Provider = "claude"
+	}
+	if ev.Model == "" {
+		ev.Model = "claude-sonnet-4-6"
+	}
+	if ev.Status == "" {
+		ev.Status = "success"
+	}
+	if err := db.Create(&ev).Error; err != nil {
+		t.Fatalf("seed spend event: %v", err)
+	}
+}
+
+func TestAnomalyDetectorPausesBuildOnTokenSpikeAndOverrideResumes(t *testing.T) {
+	d, db, pauser, notifier := anomalyTestDetector(t)
+	ctx := context.Background()
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	// Modest history: ~1k tokens/hour over the last week.
+	for h := 2; h < 7*24; h += 6 {
+		seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 1, BuildID: "old", InputTokens: 3000, OutputTokens: 3000}, now.Add(-time.Duration(h)*time.Hour))
+	}
+	// The last hour is dominated by one build.
+	for i := 0; i < 5; i++ {
+		seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 1, BuildID: "b-spike", InputTokens: 40000, OutputTokens: 20000}, now.Add(-time.Duration(i+1)*time.Minute))
+	}
+
+	flagged, err := d.RunOnce(ctx, now)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(flagged) != 1 || flagged[0].Kind != AnomalyTokenSpike || flagged[0].Action != AnomalyActionBuildPaused {
+		t.Fatalf("unexpected anomalies: %+v", flagged)
+	}
+	if len(pauser.paused) != 1 || pauser.paused[0] != "b-spike" {
+		t.Fatalf("expected b-spike to be paused, got %v", pauser.paused)
+	}
+
+	items, unread, err := notifier.List(ctx, 1, true, 10)
+	if err != nil {
+		t.Fatalf("List notifications: %v", err)
+	}
+	if unread != 1 || items[0].ActionLabel != "Override" || items[0].Kind != "spend_anomaly" {
+		t.Fatalf("unexpected notifications: %+v", items)
+	}
+
+	// A second scan must not re-flag the same build.
+	again, err := d.RunOnce(ctx, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("RunOnce rerun: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("expected dedup on rerun, got %+v", again)
+	}
+
+	overridden, err := d.Override(ctx, 1, flagged[0].ID)
+	if err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if overridden.OverriddenAt == nil || len(pauser.resumed) != 1 || pauser.resumed[0] != "b-spike" {
+		t.Fatalf("override did not resume build: %+v resumed=%v", overridden, pauser.resumed)
+	}
+	if _, err := d.Override(ctx, 2, flagged[0].ID); err != ErrAnomalyNotFound {
+		t.Fatalf("expected ErrAnomalyNotFound for another user, got %v", err)
+	}
+}
+
+func TestAnomalyDetectorPausesBYOKKeyWhenNoBuildDominates(t *testing.T) {
+	d, db, pauser, notifier := anomalyTestDetector(t)
+	ctx := context.Background()
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	key := models.UserAPIKey{UserID: 7, Provider: "gpt4", EncryptedKey: "x", KeySalt: "x", KeyFingerprint: "x", IsActive: true}
+	if err := db.Create(&key).Error; err != nil {
+		t.Fatalf("seed key: %v", err)
+	}
+	// User 7 belongs to an organization managed by user 8; user 9 is a
+	// regular member.
+	if err := db.AutoMigrate(&enterprise.Organization{}, &enterprise.Permission{}, &enterprise.Role{}, &enterprise.OrganizationMember{}); err != nil {
+		t.Fatalf("migrate orgs: %v", err)
+	}
+	org := enterprise.Organization{Name: "Acme", Slug: "acme"}
+	manage := enterprise.Permission{Name: "organization:manage", Resource: "organization", Action: "manage"}
+	db.Create(&org)
+	db.Create(&manage)
+	admin := enterprise.Role{OrganizationID: &org.ID, Name: "admin", Permissions: []enterprise.Permission{manage}}
+	member := enterprise.Role{OrganizationID: &org.ID, Name: "member"}
+	db.Create(&admin)
+	db.Create(&member)
+	for userID, roleID := range map[uint]uint{7: member.ID, 8: admin.ID, 9: member.ID} {
+		if err := db.Create(&enterprise.OrganizationMember{OrganizationID: org.ID, UserID: userID, RoleID: roleID, Status: "active"}).Error; err != nil {
+			t.Fatalf("seed member: %v", err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 7, Provider: "gpt4", IsBYOK: true, InputTokens: 40000, OutputTokens: 10000}, now.Add(-time.Duration(i+1)*time.Minute))
+	}
+
+	flagged, err := d.RunOnce(ctx, now)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(flagged) != 1 || flagged[0].Action != AnomalyActionKeyPaused || flagged[0].Provider != "gpt4" {
+		t.Fatalf("unexpected anomalies: %+v", flagged)
+	}
+	if len(flagged[0].PausedKeyIDs) != 1 || flagged[0].PausedKeyIDs[0] != key.ID {
+		t.Fatalf("expected the paused key to be recorded, got %v", flagged[0].PausedKeyIDs)
+	}
+	if len(pauser.paused) != 0 {
+		t.Fatalf("no build should be paused, got %v", pauser.paused)
+	}
+	var reloaded models.UserAPIKey
+	db.First(&reloaded, key.ID)
+	if reloaded.IsActive {
+		t.Fatal("expected BYOK key to be deactivated")
+	}
+
+	managerItems, _, err := notifier.List(ctx, 8, true, 10)
+	if err != nil {
+		t.Fatalf("List notifications: %v", err)
+	}
+	if len(managerItems) != 1 || managerItems[0].Kind != "spend_anomaly" || managerItems[0].ActionURL != "" {
+		t.Fatalf("expected the org manager to get a notification without the override, got %+v", managerItems)
+	}
+	if memberItems, _, _ := notifier.List(ctx, 9, true, 10); len(memberItems) != 0 {
+		t.Fatalf("regular members must not be notified, got %+v", memberItems)
+	}
+
+	if _, err := d.Override(ctx, 7, flagged[0].ID); err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	db.First(&reloaded, key.ID)
+	if !reloaded.IsActive {
+		t.Fatal("expected override to re-enable the BYOK key")
+	}
+}
+
+func TestAnomalyOverrideLeavesReplacementKeysAlone(t *testing.T) {
+	d, db, _, _ := anomalyTestDetector(t)
+	ctx := context.Background()
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	key := models.UserAPIKey{UserID: 7, Provider: "gpt4", EncryptedKey: "x", KeySalt: "x", KeyFingerprint: "x", IsActive: true}
+	if err := db.Create(&key).Error; err != nil {
+		t.Fatalf("seed key: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 7, Provider: "gpt4", IsBYOK: true, InputTokens: 40000, OutputTokens: 10000}, now.Add(-time.Duration(i+1)*time.Minute))
+	}
+	flagged, err := d.RunOnce(ctx, now)
+	if err != nil || len(flagged) != 1 || flagged[0].Action != AnomalyActionKeyPaused {
+		t.Fatalf("RunOnce = %+v, %v", flagged, err)
+	}
+
+	// The user replaces the paused key with a new one they keep disabled.
+	db.Unscoped().Delete(&key)
+	replacement := models.UserAPIKey{UserID: 7, Provider: "gpt4", EncryptedKey: "y", KeySalt: "y", KeyFingerprint: "y", IsActive: true}
+	if err := db.Create(&replacement).Error; err != nil {
+		t.Fatalf("seed replacement: %v", err)
+	}
+	db.Model(&replacement).Update("is_active", false)
+
+	if _, err := d.Override(ctx, 7, flagged[0].ID); err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	var reloaded models.UserAPIKey
+	db.First(&reloaded, replacement.ID)
+	if reloaded.IsActive {
+		t.Fatal("override must not re-enable a key the detector did not pause")
+	}
+}
+
+func TestAnomalyDetectorFlagsRetryLoops(t *testing.T) {
+	d, db, pauser, _ := anomalyTestDetector(t)
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 16; i++ {
+		seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 3, BuildID: "b-loop", AgentRole: "frontend", TargetFile: "src/App.tsx", InputTokens: 100}, now.Add(-time.Duration(i)*30*time.Second))
+	}
+	// Spread-out calls for a different file stay below the threshold.
+	for i := 0; i < 5; i++ {
+		seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 3, BuildID: "b-loop", AgentRole: "backend", TargetFile: "server.ts", InputTokens: 100}, now.Add(-time.Duration(i)*time.Minute))
+	}
+
+	flagged, err := d.RunOnce(context.Background(), now)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(flagged) != 1 || flagged[0].Kind != AnomalyRetryLoop || flagged[0].BuildID != "b-loop" {
+		t.Fatalf("unexpected anomalies: %+v", flagged)
+	}
+	if len(pauser.paused) != 1 {
+		t.Fatalf("expected the looping build to be paused, got %v", pauser.paused)
+	}
+}
+
+func TestAnomalyDetectorFlagsBuildConsumingDailyCap(t *testing.T) {
+	d, db, _, _ := anomalyTestDetector(t)
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	if err := db.Create(&BudgetCap{UserID: 4, CapType: "daily", LimitUSD: 10, Action: "stop", IsActive: true}).Error; err != nil {
+		t.Fatalf("seed cap: %v", err)
+	}
+	seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 4, BuildID: "b-small", BilledCost: 1}, now.Add(-3*time.Hour))
+	seedAnomalyEvent(t, db, spend.SpendEvent{UserID: 4, BuildID: "b-hog", BilledCost: 8.5}, now.Add(-2*time.Hour))
+
+	flagged, err := d.RunOnce(context.Background(), now)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(flagged) != 1 || flagged[0].Kind != AnomalyBudgetHog || flagged[0].BuildID != "b-hog" {
+		t.Fatalf("unexpected anomalies: %+v", flagged)
+	}
+	if flagged[0].BaselineValue != 10 {
+		t.Fatalf("expected daily cap as baseline, got %v", flagged[0].BaselineValue)
+	}
+}
@@ -0,0 +1,135 @@
+package buildfailures
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// Root causes a finished build is classified into.
+const (
+	CauseNone           = ""                     // completed builds
+	CauseCancelled      = "cancelled"            // stopped by the user
+	CauseProviderError  = "provider_error"       // AI provider unavailable, rate limited or misconfigured
+	CauseTruncation     = "truncation"           // model output cut off mid-file
+	CauseValidation     = "validation"           // final output validation; detail is the readiness class
+	CauseVerification   = "verification_failure" // compile, preview or integration verification
+	CauseContract       = "contract"             // build contract or coordination blocked generation
+	CauseTimeout        = "timeout"
+	CauseBudget         = "budget"         // credits or request budget exhausted
+	CauseInfrastructure = "infrastructure" // the platform failed to persist or spawn agents
+	CauseUnknown        = "unknown"
+)
+
+// Signals are what the build pipeline knows about a finished build.
+type Signals struct {
+	Status string
+	Error  string
+	// FailureCategory and FailureClass come from the build's failure taxonomy.
+	FailureCategory string
+	FailureClass    string
+	// ErrorClass is the agent's coarse class of the error text, e.g. "truncation".
+	ErrorClass string
+	// ReadinessClass groups final validation errors, e.g. "missing_build_script".
+	ReadinessClass string
+}
+
+var providerMarkers = []string{
+	"no ai providers", "no active byok", "provider", "rate limit", "429", "overloaded",
+	"api key", "quota exceeded", "503", "502", "upstream",
+}
+
+var infrastructureMarkers = []string{
+	"failed to persist", "failed to spawn", "panic", "database",
+}
+
+// Classify returns the root cause of a build and a detail that narrows it
+// (the readiness or failure class). Rules go from the most specific signal
+// to the least, so the cause is stable for the same failure.
+func Classify(s Signals) (cause, detail string) {
+	switch s.Status {
+	case "completed":
+		return CauseNone, ""
+	case "cancelled":
+		return CauseCancelled, ""
+	}
+
+	lower := strings.ToLower(s.Error)
+	errorClass := strings.TrimSpace(s.ErrorClass)
+	failureClass := strings.TrimSpace(s.FailureClass)
+	switch {
+	case errorClass == "budget" || s.FailureCategory == "budget" || strings.Contains(lower, "budget exceeded"):
+		return CauseBudget, failureClass
+	case errorClass == "truncation":
+		return CauseTruncation, failureClass
+	case strings.Contains(lower, "validation failed"):
+		return CauseValidation, strings.TrimSpace(s.ReadinessClass)
+	case containsAny(lower, providerMarkers):
+		return CauseProviderError, providerDetail(lower)
+	case containsAny(lower, infrastructureMarkers):
+		return CauseInfrastructure, ""
+	case errorClass == "timeout":
+		return CauseTimeout, ""
+	case errorClass == "contract_violation" || errorClass == "coordination_violation" || s.FailureCategory == "contract":
+		return CauseContract, failureClass
+	case errorClass == "verification_failure" || errorClass == "preview_verification" ||
+		strings.Contains(lower, "preflight") || s.FailureCategory == "compile" || s.FailureCategory == "verification" || s.FailureCategory == "preview_boot":
+		detail = failureClass
+		if detail == "" {
+			detail = s.FailureCategory
+		}
+		return CauseVerification, detail
+	}
+	if s.FailureCategory != "" && s.FailureCategory != "unknown" {
+		return CauseUnknown, s.FailureCategory
+	}
+	return CauseUnknown, failureClass
+}
+
+func providerDetail(lower string) string {
+	switch {
+	case strings.Contains(lower, "rate limit") || strings.Contains(lower, "429"):
+		return "rate_limited"
+	case strings.Contains(lower, "api key") || strings.Contains(lower, "byok"):
+		return "credentials"
+	case strings.Contains(lower, "no ai providers"):
+		return "unavailable"
+	case strings.Contains(lower, "overloaded") || strings.Contains(lower, "503") || strings.Contains(lower, "502") || strings.Contains(lower, "upstream"):
+		return "upstream_error"
+	}
+	return ""
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// DescriptionHash groups builds of the same app description. Case,
+// punctuation and whitespace are ignored so trivially edited retries match.
+func DescriptionHash(description string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(description) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		default:
+			space = true
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:16])
+}
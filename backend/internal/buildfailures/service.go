@@ -0,0 +1,316 @@
+// Package buildfailures keeps the outcome of every finished build with a
+// classified root cause, and reports the top failure causes and flaky
+// descriptions (the same app description failing intermittently) for a
+// user, an organization's members or the whole platform.
+package buildfailures
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultWindow is the report period when none is given.
+	DefaultWindow = 30 * 24 * time.Hour
+	// MaxWindow is the longest report period.
+	MaxWindow = 180 * 24 * time.Hour
+	// MinFlakyRuns is how many runs of one description are needed before it
+	// can be flagged as flaky.
+	MinFlakyRuns = 3
+	// maxReportOutcomes bounds the rows a report aggregates.
+	maxReportOutcomes = 20000
+	// maxDescription and maxErrorExcerpt cap stored text.
+	maxDescription  = 200
+	maxErrorExcerpt = 500
+)
+
+// ErrInvalidScope is returned when a report has no user, organization or
+// platform scope.
+var ErrInvalidScope = errors.New("report scope requires a user, organization or platform")
+
+// Outcome is the classified result of one finished build.
+type Outcome struct {
+	ID              uint      `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	BuildID         string    `gorm:"uniqueIndex;not null;size:64" json:"build_id"`
+	UserID          uint      `gorm:"not null;index" json:"user_id"`
+	ProjectID       *uint     `gorm:"index" json:"project_id,omitempty"`
+	DescriptionHash string    `gorm:"size:32;index" json:"description_hash"`
+	Description     string    `gorm:"size:255" json:"description"`
+	Status          string    `gorm:"not null;size:20" json:"status"`
+	RootCause       string    `gorm:"size:32;index" json:"root_cause,omitempty"`
+	Detail          string    `gorm:"size:128" json:"detail,omitempty"`
+	FailureCategory string    `gorm:"size:32" json:"failure_category,omitempty"`
+	FailureClass    string    `gorm:"size:128" json:"failure_class,omitempty"`
+	ReadinessClass  string    `gorm:"size:128" json:"readiness_class,omitempty"`
+	ErrorExcerpt    string    `gorm:"type:text" json:"error_excerpt,omitempty"`
+	Mode            string    `gorm:"size:20" json:"mode,omitempty"`
+	PowerMode       string    `gorm:"size:20" json:"power_mode,omitempty"`
+	DurationMs      int64     `json:"duration_ms"`
+	FinishedAt      time.Time `gorm:"index" json:"finished_at"`
+}
+
+// TableName keeps the table name explicit.
+func (Outcome) TableName() string { return "build_outcomes" }
+
+// Failed reports whether the outcome counts as a failure.
+func (o *Outcome) Failed() bool {
+	return o.Status == "failed"
+}
+
+// Scope selects whose builds a report covers. Exactly one of the fields is
+// used: UserID, then OrganizationID (the organization's active members),
+// then All.
+type Scope struct {
+	UserID         uint
+	OrganizationID uint
+	All            bool
+}
+
+// CauseCount is one root cause in a report.
+type CauseCount struct {
+	RootCause      string    `json:"root_cause"`
+	Detail         string    `json:"detail,omitempty"`
+	Count          int       `json:"count"`
+	Share          float64   `json:"share"` // of failed builds
+	AffectedUsers  int       `json:"affected_users"`
+	LastSeen       time.Time `json:"last_seen"`
+	ExampleBuildID string    `json:"example_build_id"`
+}
+
+// FlakyPattern is a description whose builds both pass and fail.
+type FlakyPattern struct {
+	DescriptionHash string    `json:"description_hash"`
+	Description     string    `json:"description"`
+	Runs            int       `json:"runs"`
+	Failures        int       `json:"failures"`
+	Successes       int       `json:"successes"`
+	FailureRate     float64   `json:"failure_rate"`
+	Flips           int       `json:"flips"` // pass/fail changes in run order
+	Causes          []string  `json:"causes"`
+	Users           int       `json:"users"`
+	LastFailedAt    time.Time `json:"last_failed_at"`
+	RecentBuildIDs  []string  `json:"recent_build_ids"`
+}
+
+// Report summarizes failures over a window.
+type Report struct {
+	Since        time.Time      `json:"since"`
+	Until        time.Time      `json:"until"`
+	TotalBuilds  int            `json:"total_builds"`
+	FailedBuilds int            `json:"failed_builds"`
+	Cancelled    int            `json:"cancelled_builds"`
+	FailureRate  float64        `json:"failure_rate"` // failed / (completed + failed)
+	TopCauses    []CauseCount   `json:"top_causes"`
+	Flaky        []FlakyPattern `json:"flaky"`
+}
+
+// Service records build outcomes and builds reports.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a build failure Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// AutoMigrate creates the build outcome table.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Outcome{})
+}
+
+// Record classifies and stores a finished build. Recording the same build
+// again replaces its outcome, so terminal paths may call it more than once.
+func (s *Service) Record(ctx context.Context, outcome *Outcome, signals Signals) error {
+	outcome.RootCause, outcome.Detail = Classify(signals)
+	outcome.Status = signals.Status
+	outcome.FailureCategory = signals.FailureCategory
+	outcome.FailureClass = truncate(signals.FailureClass, 128)
+	outcome.ReadinessClass = truncate(signals.ReadinessClass, 128)
+	outcome.Detail = truncate(outcome.Detail, 128)
+	outcome.DescriptionHash = DescriptionHash(outcome.Description)
+	outcome.Description = truncate(outcome.Description, maxDescription)
+	if outcome.Failed() {
+		outcome.ErrorExcerpt = truncate(signals.Error, maxErrorExcerpt)
+	}
+	if outcome.FinishedAt.IsZero() {
+		outcome.FinishedAt = time.Now().UTC()
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "build_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "project_id", "status", "root_cause", "detail", "failure_category",
+			"failure_class", "readiness_class", "error_excerpt", "duration_ms", "finished_at",
+		}),
+	}).Create(outcome).Error
+}
+
+// Report aggregates outcomes finished in [since, until) for the scope.
+func (s *Service) Report(ctx context.Context, scope Scope, since, until time.Time, limit int) (*Report, error) {
+	query := s.db.WithContext(ctx).Model(&Outcome{}).
+		Where("finished_at >= ? AND finished_at < ?", since, until)
+	switch {
+	case scope.UserID != 0:
+		query = query.Where("user_id = ?", scope.UserID)
+	case scope.OrganizationID != 0:
+		members := s.db.Table("organization_members").Select("user_id").
+			Where("organization_id = ? AND status = ? AND deleted_at IS NULL", scope.OrganizationID, "active")
+		query = query.Where("user_id IN (?)", members)
+	case !scope.All:
+		return nil, ErrInvalidScope
+	}
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	var outcomes []Outcome
+	if err := query.Omit("error_excerpt").Order("finished_at DESC, id DESC").Limit(maxReportOutcomes).Find(&outcomes).Error; err != nil {
+		return nil, err
+	}
+	// Newest rows win the cap; summarize wants them oldest first.
+	for i, j := 0, len(outcomes)-1; i < j; i, j = i+1, j-1 {
+		outcomes[i], outcomes[j] = outcomes[j], outcomes[i]
+	}
+	report := &Report{Since: since, Until: until, TopCauses: []CauseCount{}, Flaky: []FlakyPattern{}}
+	summarize(report, outcomes, limit)
+	return report, nil
+}
+
+// summarize fills the report from outcomes ordered by finish time.
+func summarize(report *Report, outcomes []Outcome, limit int) {
+	type causeKey struct{ cause, detail string }
+	causes := map[causeKey]*CauseCount{}
+	causeUsers := map[causeKey]map[uint]bool{}
+	byDescription := map[string][]*Outcome{}
+	completed := 0
+
+	for i := range outcomes {
+		o := &outcomes[i]
+		report.TotalBuilds++
+		switch o.Status {
+		case "completed":
+			completed++
+		case "cancelled":
+			report.Cancelled++
+			continue
+		case "failed":
+			report.FailedBuilds++
+			key := causeKey{o.RootCause, o.Detail}
+			c := causes[key]
+			if c == nil {
+				c = &CauseCount{RootCause: o.RootCause, Detail: o.Detail}
+				causes[key] = c
+				causeUsers[key] = map[uint]bool{}
+			}
+			c.Count++
+			causeUsers[key][o.UserID] = true
+			if !o.FinishedAt.Before(c.LastSeen) {
+				c.LastSeen = o.FinishedAt
+				c.ExampleBuildID = o.BuildID
+			}
+		default:
+			continue
+		}
+		if o.DescriptionHash != "" {
+			byDescription[o.DescriptionHash] = append(byDescription[o.DescriptionHash], o)
+		}
+	}
+	if decided := completed + report.FailedBuilds; decided > 0 {
+		report.FailureRate = ratio(report.FailedBuilds, decided)
+	}
+
+	for key, c := range causes {
+		c.Share = ratio(c.Count, report.FailedBuilds)
+		c.AffectedUsers = len(causeUsers[key])
+		report.TopCauses = append(report.TopCauses, *c)
+	}
+	sort.Slice(report.TopCauses, func(i, j int) bool {
+		a, b := report.TopCauses[i], report.TopCauses[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.LastSeen.After(b.LastSeen)
+	})
+	if len(report.TopCauses) > limit {
+		report.TopCauses = report.TopCauses[:limit]
+	}
+
+	for hash, runs := range byDescription {
+		if pattern, ok := flakyPattern(hash, runs); ok {
+			report.Flaky = append(report.Flaky, pattern)
+		}
+	}
+	sort.Slice(report.Flaky, func(i, j int) bool {
+		a, b := report.Flaky[i], report.Flaky[j]
+		if a.Flips != b.Flips {
+			return a.Flips > b.Flips
+		}
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.LastFailedAt.After(b.LastFailedAt)
+	})
+	if len(report.Flaky) > limit {
+		report.Flaky = report.Flaky[:limit]
+	}
+}
+
+// flakyPattern flags a description when at least MinFlakyRuns builds of it
+// both passed and failed; runs are in finish order.
+func flakyPattern(hash string, runs []*Outcome) (FlakyPattern, bool) {
+	if len(runs) < MinFlakyRuns {
+		return FlakyPattern{}, false
+	}
+	pattern := FlakyPattern{DescriptionHash: hash, Runs: len(runs), Causes: []string{}}
+	users := map[uint]bool{}
+	seenCause := map[string]bool{}
+	for i, o := range runs {
+		users[o.UserID] = true
+		pattern.Description = o.Description
+		if o.Failed() {
+			pattern.Failures++
+			pattern.LastFailedAt = o.FinishedAt
+			if !seenCause[o.RootCause] {
+				seenCause[o.RootCause] = true
+				pattern.Causes = append(pattern.Causes, o.RootCause)
+			}
+		} else {
+			pattern.Successes++
+		}
+		if i > 0 && o.Failed() != runs[i-1].Failed() {
+			pattern.Flips++
+		}
+	}
+	if pattern.Failures == 0 || pattern.Successes == 0 {
+		return FlakyPattern{}, false
+	}
+	pattern.Users = len(users)
+	pattern.FailureRate = ratio(pattern.Failures, pattern.Runs)
+	for i := len(runs) - 1; i >= 0 && len(pattern.RecentBuildIDs) < 5; i-- {
+		pattern.RecentBuildIDs = append(pattern.RecentBuildIDs, runs[i].BuildID)
+	}
+	sort.Strings(pattern.Causes)
+	return pattern, true
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
@@ -0,0 +1,138 @@
+package buildfailures
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     Signals
+		cause  string
+		detail string
+	}{
+		{"completed", Signals{Status: "completed", Error: "ignored"}, CauseNone, ""},
+		{"cancelled", Signals{Status: "cancelled"}, CauseCancelled, ""},
+		{"budget", Signals{Status: "failed", Error: "request budget exceeded", ErrorClass: "budget"}, CauseBudget, ""},
+		{"truncation", Signals{Status: "failed", Error: "output cut off", ErrorClass: "truncation", FailureClass: "unterminated_file"}, CauseTruncation, "unterminated_file"},
+		{"validation mentioning a provider", Signals{Status: "failed", Error: "final output validation failed: AuthProvider missing", ReadinessClass: "missing_entrypoint"}, CauseValidation, "missing_entrypoint"},
+		{"rate limited", Signals{Status: "failed", Error: "openai: 429 Too Many Requests"}, CauseProviderError, "rate_limited"},
+		{"no providers", Signals{Status: "failed", Error: "No AI providers are available"}, CauseProviderError, "unavailable"},
+		{"infrastructure", Signals{Status: "failed", Error: "failed to persist project files"}, CauseInfrastructure, ""},
+		{"timeout", Signals{Status: "failed", Error: "build exceeded its time limit", ErrorClass: "timeout"}, CauseTimeout, ""},
+		{"contract", Signals{Status: "failed", Error: "blocked", FailureCategory: "contract", FailureClass: "missing_route"}, CauseContract, "missing_route"},
+		{"verification", Signals{Status: "failed", Error: "tsc exited 2", FailureCategory: "compile"}, CauseVerification, "compile"},
+		{"unknown", Signals{Status: "failed", Error: "something odd"}, CauseUnknown, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cause, detail := Classify(tc.in)
+			require.Equal(t, tc.cause, cause)
+			require.Equal(t, tc.detail, detail)
+		})
+	}
+}
+
+func TestDescriptionHashIgnoresFormatting(t *testing.T) {
+	require.Equal(t, DescriptionHash("Build a Todo app!"), DescriptionHash("  build a todo   APP "))
+	require.NotEqual(t, DescriptionHash("Build a todo app"), DescriptionHash("Build a chat app"))
+	require.Empty(t, DescriptionHash(" ... "))
+}
+
+func setupBuildFailuresTest(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, AutoMigrate(db))
+	require.NoError(t, db.Exec(`CREATE TABLE organization_members (
+		id integer primary key, organization_id integer, user_id integer, status text, deleted_at datetime)`).Error)
+	return NewService(db), db
+}
+
+func record(t *testing.T, service *Service, id string, userID uint, description string, finishedAt time.Time, signals Signals) {
+	t.Helper()
+	outcome := &Outcome{BuildID: id, UserID: userID, Description: description, FinishedAt: finishedAt}
+	require.NoError(t, service.Record(context.Background(), outcome, signals))
+}
+
+func TestRecordReplacesOutcomeForSameBuild(t *testing.T) {
+	service, db := setupBuildFailuresTest(t)
+	now := time.Now().UTC()
+	record(t, service, "b-1", 1, "Todo app", now, Signals{Status: "failed", Error: "openai: 429"})
+	record(t, service, "b-1", 1, "Todo app", now, Signals{Status: "completed"})
+
+	var outcomes []Outcome
+	require.NoError(t, db.Find(&outcomes).Error)
+	require.Len(t, outcomes, 1)
+	require.Equal(t, "completed", outcomes[0].Status)
+	require.Equal(t, CauseNone, outcomes[0].RootCause)
+	require.Empty(t, outcomes[0].ErrorExcerpt)
+}
+
+func TestReportTopCausesAndFlakyDescriptions(t *testing.T) {
+	service, db := setupBuildFailuresTest(t)
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-48 * time.Hour)
+	at := func(i int) time.Time { return base.Add(time.Duration(i) * time.Minute) }
+
+	// Same description, trivially reworded: pass, fail, pass, fail
+	for i, status := range []string{"completed", "failed", "completed", "failed"} {
+		description := "Build a todo app"
+		if i%2 == 1 {
+			description = "build a TODO app."
+		}
+		record(t, service, fmt.Sprintf("todo-%d", i), 1, description, at(i), Signals{Status: status, Error: "openai: 429 rate limit"})
+	}
+	// Always fails: not flaky
+	for i := 0; i < 3; i++ {
+		record(t, service, fmt.Sprintf("crm-%d", i), 2, "CRM dashboard", at(10+i), Signals{Status: "failed", Error: "tsc exited 2", FailureCategory: "compile"})
+	}
+	record(t, service, "chat-0", 2, "Chat app", at(20), Signals{Status: "cancelled"})
+	record(t, service, "old-0", 1, "Old app", base.Add(-60*24*time.Hour), Signals{Status: "failed", Error: "something odd"})
+
+	report, err := service.Report(ctx, Scope{All: true}, base.Add(-time.Hour), time.Now().UTC(), 10)
+	require.NoError(t, err)
+	require.Equal(t, 8, report.TotalBuilds)
+	require.Equal(t, 5, report.FailedBuilds)
+	require.Equal(t, 1, report.Cancelled)
+	require.InDelta(t, 5.0/7.0, report.FailureRate, 0.0001)
+
+	require.Len(t, report.TopCauses, 2)
+	require.Equal(t, CauseVerification, report.TopCauses[0].RootCause)
+	require.Equal(t, "compile", report.TopCauses[0].Detail)
+	require.Equal(t, 3, report.TopCauses[0].Count)
+	require.Equal(t, "crm-2", report.TopCauses[0].ExampleBuildID)
+	require.Equal(t, CauseProviderError, report.TopCauses[1].RootCause)
+	require.Equal(t, "rate_limited", report.TopCauses[1].Detail)
+
+	require.Len(t, report.Flaky, 1)
+	flaky := report.Flaky[0]
+	require.Equal(t, 4, flaky.Runs)
+	require.Equal(t, 2, flaky.Failures)
+	require.Equal(t, 3, flaky.Flips)
+	require.Equal(t, []string{CauseProviderError}, flaky.Causes)
+	require.Equal(t, []string{"todo-3", "todo-2", "todo-1", "todo-0"}, flaky.RecentBuildIDs)
+
+	// User scope only sees the user's own builds
+	report, err = service.Report(ctx, Scope{UserID: 2}, base.Add(-time.Hour), time.Now().UTC(), 10)
+	require.NoError(t, err)
+	require.Equal(t, 4, report.TotalBuilds)
+	require.Empty(t, report.Flaky)
+
+	// Organization scope covers active members only
+	require.NoError(t, db.Exec(`INSERT INTO organization_members (organization_id, user_id, status) VALUES (7, 1, 'active'), (7, 2, 'invited')`).Error)
+	report, err = service.Report(ctx, Scope{OrganizationID: 7}, base.Add(-time.Hour), time.Now().UTC(), 10)
+	require.NoError(t, err)
+	require.Equal(t, 4, report.TotalBuilds)
+	require.Len(t, report.Flaky, 1)
+
+	_, err = service.Report(ctx, Scope{}, base, time.Now().UTC(), 10)
+	require.ErrorIs(t, err, ErrInvalidScope)
+}
@@ -0,0 +1,176 @@
+// Package buildhooks runs the user-defined hooks a project declares in its
+// apex.yaml. Hooks are shell commands that run in the container sandbox
+// against a copy of the project's files: pre_build before agents start,
+// post_build on the generated output before a build is marked complete, and
+// pre_deploy before a deployment builds. A failing hook blocks the pipeline
+// unless it is marked on_failure: warn.
+package buildhooks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FileName is the project config file at the project root. apex.yml is
+	// accepted too.
+	FileName = "apex.yaml"
+
+	// DefaultTimeout applies to hooks that do not set one.
+	DefaultTimeout = 2 * time.Minute
+	// MaxTimeout caps a single hook.
+	MaxTimeout = 10 * time.Minute
+	// MaxStageDuration caps all hooks of one stage together; hooks that would
+	// start after it has run out are skipped.
+	MaxStageDuration = 15 * time.Minute
+	// MaxHooksPerStage caps the hooks one stage may declare.
+	MaxHooksPerStage = 5
+	// MaxOutputBytes is how much of a hook's output is kept, from the end.
+	MaxOutputBytes = 16 * 1024
+	// MaxConfigBytes caps apex.yaml.
+	MaxConfigBytes = 64 * 1024
+)
+
+// Stage identifies when hooks run.
+type Stage string
+
+const (
+	StagePreBuild  Stage = "pre_build"
+	StagePostBuild Stage = "post_build"
+	StagePreDeploy Stage = "pre_deploy"
+)
+
+// Failure policies for a hook.
+const (
+	OnFailureBlock = "block"
+	OnFailureWarn  = "warn"
+)
+
+// ErrInvalidConfig is returned when apex.yaml cannot be used.
+var ErrInvalidConfig = errors.New("invalid apex.yaml")
+
+// configFilePaths are the stored paths that count as the root apex.yaml.
+var configFilePaths = []string{FileName, "/" + FileName, "./" + FileName, "apex.yml", "/apex.yml", "./apex.yml"}
+
+// Hook is one command in a stage.
+type Hook struct {
+	Name string `yaml:"name" json:"name"`
+	Run  string `yaml:"run" json:"run"`
+	// Timeout is a Go duration such as "90s" or "5m".
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// OnFailure is "block" (the default) or "warn".
+	OnFailure string `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+
+	timeout time.Duration
+}
+
+// UnmarshalYAML accepts a plain command string as shorthand for {run: ...}.
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		h.Run = node.Value
+		return nil
+	}
+	type plain Hook
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*h = Hook(p)
+	return nil
+}
+
+// Blocking reports whether the hook's failure stops the pipeline.
+func (h Hook) Blocking() bool {
+	return h.OnFailure != OnFailureWarn
+}
+
+// EffectiveTimeout is the hook's validated timeout.
+func (h Hook) EffectiveTimeout() time.Duration {
+	if h.timeout <= 0 {
+		return DefaultTimeout
+	}
+	return h.timeout
+}
+
+// Config is the hooks section of apex.yaml.
+type Config struct {
+	PreBuild  []Hook `yaml:"pre_build" json:"pre_build"`
+	PostBuild []Hook `yaml:"post_build" json:"post_build"`
+	PreDeploy []Hook `yaml:"pre_deploy" json:"pre_deploy"`
+}
+
+// Hooks returns the hooks declared for stage.
+func (c *Config) Hooks(stage Stage) []Hook {
+	if c == nil {
+		return nil
+	}
+	switch stage {
+	case StagePreBuild:
+		return c.PreBuild
+	case StagePostBuild:
+		return c.PostBuild
+	case StagePreDeploy:
+		return c.PreDeploy
+	}
+	return nil
+}
+
+// Parse reads the hooks section of apex.yaml and validates it. Other
+// top-level keys are ignored.
+func Parse(content string) (*Config, error) {
+	if len(content) > MaxConfigBytes {
+		return nil, fmt.Errorf("%w: file exceeds %d bytes", ErrInvalidConfig, MaxConfigBytes)
+	}
+	var doc struct {
+		Hooks Config `yaml:"hooks"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	cfg := &doc.Hooks
+	for _, stage := range []Stage{StagePreBuild, StagePostBuild, StagePreDeploy} {
+		hooks := cfg.Hooks(stage)
+		if len(hooks) > MaxHooksPerStage {
+			return nil, fmt.Errorf("%w: %s has %d hooks, at most %d are allowed", ErrInvalidConfig, stage, len(hooks), MaxHooksPerStage)
+		}
+		for i := range hooks {
+			if err := validateHook(&hooks[i], i); err != nil {
+				return nil, fmt.Errorf("%w: %s[%d]: %v", ErrInvalidConfig, stage, i, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func validateHook(h *Hook, index int) error {
+	h.Run = strings.TrimSpace(h.Run)
+	if h.Run == "" {
+		return errors.New("run is required")
+	}
+	h.Name = strings.TrimSpace(h.Name)
+	if h.Name == "" {
+		h.Name = fmt.Sprintf("hook-%d", index+1)
+	}
+	switch h.OnFailure = strings.ToLower(strings.TrimSpace(h.OnFailure)); h.OnFailure {
+	case "":
+		h.OnFailure = OnFailureBlock
+	case OnFailureBlock, OnFailureWarn:
+	default:
+		return fmt.Errorf("on_failure must be %q or %q", OnFailureBlock, OnFailureWarn)
+	}
+	if t := strings.TrimSpace(h.Timeout); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("timeout %q is not a duration such as \"90s\"", t)
+		}
+		if d > MaxTimeout {
+			return fmt.Errorf("timeout %s exceeds %s", d, MaxTimeout)
+		}
+		h.timeout = d
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package buildhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"apex-build/internal/execution"
+	"apex-build/internal/schedules"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Executor runs a command in the container sandbox against files keyed by
+// path. Implemented by handlers.ExecutionHandler.
+type Executor interface {
+	RunFilesCommand(ctx context.Context, userID uint, language string, files map[string]string, command string, timeout time.Duration) (*execution.ExecutionResult, error)
+}
+
+// Quota reserves execution minutes for each hook. Implemented by
+// *usage.Tracker.
+type Quota interface {
+	ReserveExecution(ctx context.Context, userID uint, plan usage.PlanType, timeout time.Duration, enforce bool) (*usage.ExecutionReservation, error)
+	SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*usage.ExecutionReservation, error)
+	ReleaseExecution(ctx context.Context, reservationID string) error
+}
+
+// Hook result statuses.
+const (
+	StatusPassed   = "passed"
+	StatusFailed   = "failed"
+	StatusTimedOut = "timed_out"
+	StatusError    = "error"
+	StatusSkipped  = "skipped"
+)
+
+// Event types sent while a stage runs.
+const (
+	EventStarted  = "started"
+	EventOutput   = "output"
+	EventFinished = "finished"
+)
+
+// Request describes the files a stage runs against.
+type Request struct {
+	UserID uint
+	// ProjectID loads the project's stored files; 0 when the files have no
+	// project yet.
+	ProjectID uint
+	// Files are laid over the project's files by path.
+	Files map[string]string
+	// Language picks the sandbox image; detected from the files when empty.
+	Language string
+}
+
+// HookResult is the outcome of one hook.
+type HookResult struct {
+	Name        string `json:"name"`
+	Command     string `json:"command"`
+	Status      string `json:"status"`
+	ExitCode    int    `json:"exit_code"`
+	DurationMs  int64  `json:"duration_ms"`
+	Blocking    bool   `json:"blocking"`
+	Output      string `json:"output,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"`
+	Error       string `json:"error,omitempty"`
+	ExecutionID string `json:"execution_id,omitempty"`
+}
+
+// Failed reports whether the hook ran and did not pass.
+func (r HookResult) Failed() bool {
+	return r.Status != StatusPassed && r.Status != StatusSkipped
+}
+
+// StageResult is the outcome of one stage.
+type StageResult struct {
+	Stage     Stage        `json:"stage"`
+	Hooks     []HookResult `json:"hooks"`
+	Blocked   bool         `json:"blocked"`
+	BlockedBy string       `json:"blocked_by,omitempty"`
+}
+
+// BlockError describes the hook that blocked the pipeline, or "" if none did.
+func (r *StageResult) BlockError() string {
+	if r == nil || !r.Blocked {
+		return ""
+	}
+	for _, h := range r.Hooks {
+		if h.Name != r.BlockedBy {
+			continue
+		}
+		switch h.Status {
+		case StatusTimedOut:
+			return fmt.Sprintf("%s hook %q timed out", r.Stage, h.Name)
+		case StatusError:
+			return fmt.Sprintf("%s hook %q could not run: %s", r.Stage, h.Name, h.Error)
+		default:
+			return fmt.Sprintf("%s hook %q failed (exit %d)", r.Stage, h.Name, h.ExitCode)
+		}
+	}
+	return fmt.Sprintf("%s hook %q failed", r.Stage, r.BlockedBy)
+}
+
+// Event reports hook progress to the caller, which forwards it to the build
+// WebSocket or the deployment log.
+type Event struct {
+	Type    string      `json:"type"`
+	Stage   Stage       `json:"stage"`
+	Hook    string      `json:"hook"`
+	Index   int         `json:"index"`
+	Command string      `json:"command,omitempty"`
+	Output  string      `json:"output,omitempty"`
+	Result  *HookResult `json:"result,omitempty"`
+}
+
+// Runner loads apex.yaml and runs a stage's hooks.
+type Runner struct {
+	db       *gorm.DB
+	executor Executor
+	quota    Quota
+}
+
+// NewRunner creates a new Runner.
+func NewRunner(db *gorm.DB) *Runner {
+	return &Runner{db: db}
+}
+
+// SetExecutor sets the sandbox hooks run in. Without one, declared hooks
+// fail with StatusError.
+func (r *Runner) SetExecutor(executor Executor) {
+	r.executor = executor
+}
+
+// SetQuota charges each hook's run time to the requesting user's execution
+// minutes. A hook that cannot reserve minutes fails with StatusError.
+func (r *Runner) SetQuota(quota Quota) {
+	r.quota = quota
+}
+
+// Run runs the hooks apex.yaml declares for stage in order. It returns nil
+// when there is no apex.yaml or the stage has no hooks. After a blocking
+// failure the remaining hooks are skipped. An apex.yaml that does not parse
+// returns an error wrapping ErrInvalidConfig.
+func (r *Runner) Run(ctx context.Context, stage Stage, req Request, emit func(Event)) (*StageResult, error) {
+	if r == nil {
+		return nil, nil
+	}
+	files, language, err := r.loadFiles(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := configContent(files)
+	if !ok {
+		return nil, nil
+	}
+	cfg, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	hooks := cfg.Hooks(stage)
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	if req.Language != "" {
+		language = req.Language
+	}
+	if emit == nil {
+		emit = func(Event) {}
+	}
+
+	stageCtx, cancel := context.WithTimeout(ctx, MaxStageDuration)
+	defer cancel()
+	result := &StageResult{Stage: stage, Hooks: make([]HookResult, 0, len(hooks))}
+	for i, hook := range hooks {
+		hr := HookResult{Name: hook.Name, Command: hook.Run, Blocking: hook.Blocking()}
+		if result.Blocked || stageCtx.Err() != nil {
+			hr.Status = StatusSkipped
+			result.Hooks = append(result.Hooks, hr)
+			emit(Event{Type: EventFinished, Stage: stage, Hook: hook.Name, Index: i, Command: hook.Run, Result: &hr})
+			continue
+		}
+
+		emit(Event{Type: EventStarted, Stage: stage, Hook: hook.Name, Index: i, Command: hook.Run})
+		r.runHook(stageCtx, req, language, files, hook, &hr)
+		if hr.Output != "" {
+			for _, chunk := range outputChunks(hr.Output) {
+				emit(Event{Type: EventOutput, Stage: stage, Hook: hook.Name, Index: i, Output: chunk})
+			}
+		}
+		result.Hooks = append(result.Hooks, hr)
+		emit(Event{Type: EventFinished, Stage: stage, Hook: hook.Name, Index: i, Command: hook.Run, Result: &hr})
+		if hr.Failed() && hr.Blocking {
+			result.Blocked = true
+			result.BlockedBy = hook.Name
+		}
+	}
+	return result, nil
+}
+
+func (r *Runner) runHook(ctx context.Context, req Request, language string, files map[string]string, hook Hook, hr *HookResult) {
+	started := time.Now()
+	defer func() { hr.DurationMs = time.Since(started).Milliseconds() }()
+	if r.executor == nil {
+		hr.Status = StatusError
+		hr.Error = "the container sandbox is not available"
+		return
+	}
+	timeout := hook.EffectiveTimeout()
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	reservationID := ""
+	if r.quota != nil {
+		plan, enforce := schedules.BillingFor(ctx, r.db, req.UserID)
+		reservation, err := r.quota.ReserveExecution(ctx, req.UserID, plan, timeout, enforce)
+		if err != nil {
+			hr.Status = StatusError
+			hr.Error = "could not reserve execution minutes: " + err.Error()
+			var quotaErr *usage.ExecutionQuotaError
+			if errors.As(err, &quotaErr) {
+				hr.Error = quotaErr.Error()
+			}
+			return
+		}
+		reservationID = reservation.ID
+	}
+	res, err := r.executor.RunFilesCommand(ctx, req.UserID, language, files, hook.Run, timeout)
+	if err != nil {
+		if reservationID != "" {
+			if releaseErr := r.quota.ReleaseExecution(context.Background(), reservationID); releaseErr != nil {
+				log.Printf("buildhooks: failed to release reservation %s: %v", reservationID, releaseErr)
+			}
+		}
+		hr.Status = StatusError
+		hr.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			hr.Status = StatusTimedOut
+		}
+		return
+	}
+	if reservationID != "" {
+		var projectID *uint
+		if req.ProjectID != 0 {
+			projectID = &req.ProjectID
+		}
+		if _, err := r.quota.SettleExecution(context.Background(), reservationID, projectID, res.DurationMs, res.CPUTime); err != nil {
+			log.Printf("buildhooks: failed to settle execution for hook %q: %v", hook.Name, err)
+		}
+	}
+	hr.ExecutionID = res.ID
+	hr.ExitCode = res.ExitCode
+	output := res.Output
+	if res.ErrorOutput != "" {
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		output += res.ErrorOutput
+	}
+	if len(output) > MaxOutputBytes {
+		output = output[len(output)-MaxOutputBytes:]
+		hr.Truncated = true
+	}
+	hr.Output = output
+	switch {
+	case res.TimedOut || res.Status == "timeout":
+		hr.Status = StatusTimedOut
+	case res.ExitCode == 0 && res.Status != "failed" && res.Status != "killed":
+		hr.Status = StatusPassed
+	default:
+		hr.Status = StatusFailed
+	}
+}
+
+// loadFiles returns the project's files with req.Files laid over them, and
+// the language to run them with.
+func (r *Runner) loadFiles(ctx context.Context, req Request) (map[string]string, string, error) {
+	files := make(map[string]string, len(req.Files))
+	language := ""
+	if req.ProjectID != 0 && r.db != nil {
+		var project models.Project
+		if err := r.db.WithContext(ctx).Select("id", "language").First(&project, req.ProjectID).Error; err != nil {
+			return nil, "", fmt.Errorf("load project %d: %w", req.ProjectID, err)
+		}
+		language = project.Language
+		var stored []models.File
+		if err := r.db.WithContext(ctx).Where("project_id = ? AND type <> ?", req.ProjectID, "directory").
+			Select("path", "content").Find(&stored).Error; err != nil {
+			return nil, "", fmt.Errorf("load project files: %w", err)
+		}
+		for _, f := range stored {
+			files[normalizePath(f.Path)] = f.Content
+		}
+	}
+	for p, content := range req.Files {
+		files[normalizePath(p)] = content
+	}
+	switch language {
+	case "":
+		language = detectLanguage(files)
+	case "typescript":
+		language = "javascript"
+	}
+	return files, language, nil
+}
+
+func configContent(files map[string]string) (string, bool) {
+	for _, p := range configFilePaths {
+		if content, ok := files[normalizePath(p)]; ok {
+			return content, true
+		}
+	}
+	return "", false
+}
+
+func normalizePath(p string) string {
+	p = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(p), "./"), "/")
+	return path.Clean("/" + p)[1:]
+}
+
+// detectLanguage picks a sandbox image from the project's manifests.
+func detectLanguage(files map[string]string) string {
+	switch {
+	case has(files, "package.json"):
+		return "javascript"
+	case has(files, "requirements.txt"), has(files, "pyproject.toml"):
+		return "python"
+	case has(files, "go.mod"):
+		return "go"
+	case has(files, "Cargo.toml"):
+		return "rust"
+	}
+	return "javascript"
+}
+
+func has(files map[string]string, p string) bool {
+	_, ok := files[p]
+	return ok
+}
+
+// outputChunks splits output into line-aligned chunks of at most 4KB.
+func outputChunks(output string) []string {
+	const chunkSize = 4 * 1024
+	var chunks []string
+	for len(output) > chunkSize {
+		cut := strings.LastIndexByte(output[:chunkSize], '\n') + 1
+		if cut <= 0 {
+			cut = chunkSize
+		}
+		chunks = append(chunks, output[:cut])
+		output = output[cut:]
+	}
+	if output != "" {
+		chunks = append(chunks, output)
+	}
+	return chunks
+}
+
+// LogLine renders the event for a deployment log, with the level the line
+// should be logged at.
+func (e Event) LogLine() (level, message string) {
+	switch e.Type {
+	case EventStarted:
+		return "info", fmt.Sprintf("Running %s hook %q: %s", e.Stage, e.Hook, e.Command)
+	case EventOutput:
+		return "info", strings.TrimRight(e.Output, "\n")
+	case EventFinished:
+		r := e.Result
+		if r == nil {
+			return "", ""
+		}
+		switch {
+		case r.Status == StatusPassed:
+			return "info", fmt.Sprintf("Hook %q passed in %dms", r.Name, r.DurationMs)
+		case r.Status == StatusSkipped:
+			return "warn", fmt.Sprintf("Hook %q skipped", r.Name)
+		case r.Blocking:
+			return "error", fmt.Sprintf("Hook %q %s (exit %d)%s", r.Name, r.Status, r.ExitCode, errorSuffix(r.Error))
+		default:
+			return "warn", fmt.Sprintf("Hook %q %s (exit %d), continuing because on_failure is warn%s", r.Name, r.Status, r.ExitCode, errorSuffix(r.Error))
+		}
+	}
+	return "", ""
+}
+
+func errorSuffix(err string) string {
+	if err == "" {
+		return ""
+	}
+	return ": " + err
+}
@@ -0,0 +1,211 @@
+package buildhooks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/execution"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type fakeCall struct {
+	language string
+	files    map[string]string
+	command  string
+	timeout  time.Duration
+}
+
+type fakeExecutor struct {
+	calls   []fakeCall
+	results map[string]*execution.ExecutionResult
+	errs    map[string]error
+}
+
+func (f *fakeExecutor) RunFilesCommand(ctx context.Context, userID uint, language string, files map[string]string, command string, timeout time.Duration) (*execution.ExecutionResult, error) {
+	f.calls = append(f.calls, fakeCall{language: language, files: files, command: command, timeout: timeout})
+	if err := f.errs[command]; err != nil {
+		return nil, err
+	}
+	if res := f.results[command]; res != nil {
+		return res, nil
+	}
+	return &execution.ExecutionResult{ID: "exec-" + command, Status: "completed", Output: "ok\n"}, nil
+}
+
+type fakeQuota struct {
+	exceeded bool
+	reserved []time.Duration
+	settled  []*uint
+	released int
+}
+
+func (f *fakeQuota) ReserveExecution(ctx context.Context, userID uint, plan usage.PlanType, timeout time.Duration, enforce bool) (*usage.ExecutionReservation, error) {
+	if f.exceeded {
+		return nil, &usage.ExecutionQuotaError{Used: 60, Requested: 5, Limit: 60}
+	}
+	f.reserved = append(f.reserved, timeout)
+	return &usage.ExecutionReservation{ID: "res-1", UserID: userID}, nil
+}
+
+func (f *fakeQuota) SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*usage.ExecutionReservation, error) {
+	f.settled = append(f.settled, projectID)
+	return &usage.ExecutionReservation{ID: reservationID}, nil
+}
+
+func (f *fakeQuota) ReleaseExecution(ctx context.Context, reservationID string) error {
+	f.released++
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(`
+name: shop
+hooks:
+  pre_build:
+    - npm run lint
+  post_build:
+    - name: tests
+      run: npm test
+      timeout: 5m
+      on_failure: warn
+`)
+	require.NoError(t, err)
+	require.Len(t, cfg.Hooks(StagePreBuild), 1)
+	lint := cfg.Hooks(StagePreBuild)[0]
+	require.Equal(t, "hook-1", lint.Name)
+	require.True(t, lint.Blocking())
+	require.Equal(t, DefaultTimeout, lint.EffectiveTimeout())
+	tests := cfg.Hooks(StagePostBuild)[0]
+	require.False(t, tests.Blocking())
+	require.Equal(t, 5*time.Minute, tests.EffectiveTimeout())
+	require.Empty(t, cfg.Hooks(StagePreDeploy))
+
+	for _, bad := range []string{
+		"hooks:\n  pre_build:\n    - name: empty\n",
+		"hooks:\n  pre_build:\n    - run: x\n      timeout: 1h\n",
+		"hooks:\n  pre_build:\n    - run: x\n      timeout: soon\n",
+		"hooks:\n  pre_deploy:\n    - run: x\n      on_failure: ignore\n",
+		"hooks:\n  pre_build: [a, b, c, d, e, f]\n",
+		"hooks: [",
+	} {
+		_, err := Parse(bad)
+		require.ErrorIs(t, err, ErrInvalidConfig, bad)
+	}
+}
+
+func TestRunBlocksAndWarnsPerConfig(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Project{}, &models.File{}))
+	project := models.Project{Name: "shop", Language: "python", OwnerID: 7}
+	require.NoError(t, db.Create(&project).Error)
+	require.NoError(t, db.Create(&[]models.File{
+		{ProjectID: project.ID, Path: "/apex.yaml", Name: "apex.yaml", Type: "file", Content: `hooks:
+  pre_build:
+    - name: lint
+      run: flake8
+      on_failure: warn
+    - name: tests
+      run: pytest
+      timeout: 30s
+    - name: audit
+      run: pip-audit
+  pre_deploy:
+    - run: ./check.sh
+`},
+		{ProjectID: project.ID, Path: "/app.py", Name: "app.py", Type: "file", Content: "print(1)"},
+	}).Error)
+
+	exec := &fakeExecutor{
+		results: map[string]*execution.ExecutionResult{
+			"flake8": {Status: "failed", ExitCode: 1, Output: "app.py:1: E1\n"},
+			"pytest": {Status: "failed", ExitCode: 2, ErrorOutput: strings.Repeat("x", MaxOutputBytes+10)},
+		},
+	}
+	runner := NewRunner(db)
+	runner.SetExecutor(exec)
+
+	var events []Event
+	result, err := runner.Run(context.Background(), StagePreBuild,
+		Request{UserID: 7, ProjectID: project.ID, Files: map[string]string{"app.py": "print(2)"}},
+		func(ev Event) { events = append(events, ev) })
+	require.NoError(t, err)
+	require.True(t, result.Blocked)
+	require.Equal(t, "tests", result.BlockedBy)
+	require.Equal(t, `pre_build hook "tests" failed (exit 2)`, result.BlockError())
+	require.Len(t, result.Hooks, 3)
+	require.Equal(t, StatusFailed, result.Hooks[0].Status)
+	require.False(t, result.Hooks[0].Blocking)
+	require.True(t, result.Hooks[1].Truncated)
+	require.Len(t, result.Hooks[1].Output, MaxOutputBytes)
+	require.Equal(t, StatusSkipped, result.Hooks[2].Status)
+
+	require.Len(t, exec.calls, 2)
+	require.Equal(t, "python", exec.calls[0].language)
+	require.Equal(t, "print(2)", exec.calls[0].files["app.py"])
+	require.Contains(t, exec.calls[0].files, "apex.yaml")
+	require.Equal(t, 30*time.Second, exec.calls[1].timeout)
+	require.Equal(t, EventStarted, events[0].Type)
+	require.Equal(t, EventOutput, events[1].Type)
+	require.Equal(t, "app.py:1: E1\n", events[1].Output)
+	last := events[len(events)-1]
+	require.Equal(t, EventFinished, last.Type)
+	require.Equal(t, StatusSkipped, last.Result.Status)
+	level, message := last.LogLine()
+	require.Equal(t, "warn", level)
+	require.Equal(t, `Hook "audit" skipped`, message)
+
+	// No hooks for the stage, or no apex.yaml at all
+	result, err = runner.Run(context.Background(), StagePostBuild, Request{UserID: 7, ProjectID: project.ID}, nil)
+	require.NoError(t, err)
+	require.Nil(t, result)
+	result, err = runner.Run(context.Background(), StagePreDeploy, Request{UserID: 7, Files: map[string]string{"package.json": "{}"}}, nil)
+	require.NoError(t, err)
+	require.Nil(t, result)
+
+	// Sandbox errors follow on_failure like any other failure
+	exec.errs = map[string]error{"./check.sh": errors.New("container sandbox not available")}
+	result, err = runner.Run(context.Background(), StagePreDeploy, Request{UserID: 7, ProjectID: project.ID}, nil)
+	require.NoError(t, err)
+	require.Equal(t, `pre_deploy hook "hook-1" could not run: container sandbox not available`, result.BlockError())
+
+	_, err = runner.Run(context.Background(), StagePreBuild, Request{UserID: 7, Files: map[string]string{"apex.yml": "hooks:\n  pre_build:\n    - run: ''\n"}}, nil)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestRunChargesExecutionMinutes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}))
+	files := map[string]string{"apex.yaml": "hooks:\n  pre_build:\n    - run: pytest\n      timeout: 30s\n    - run: ./broken.sh\n      on_failure: warn\n"}
+
+	exec := &fakeExecutor{errs: map[string]error{"./broken.sh": errors.New("sandbox crashed")}}
+	quota := &fakeQuota{}
+	runner := NewRunner(db)
+	runner.SetExecutor(exec)
+	runner.SetQuota(quota)
+
+	result, err := runner.Run(context.Background(), StagePreBuild, Request{UserID: 7, Files: files}, nil)
+	require.NoError(t, err)
+	require.False(t, result.Blocked)
+	require.Equal(t, []time.Duration{30 * time.Second, DefaultTimeout}, quota.reserved)
+	require.Equal(t, []*uint{nil}, quota.settled)
+	require.Equal(t, 1, quota.released)
+
+	// Out of minutes: blocking hooks block without reaching the sandbox
+	quota.exceeded = true
+	exec.calls = nil
+	result, err = runner.Run(context.Background(), StagePreBuild, Request{UserID: 7, Files: files}, nil)
+	require.NoError(t, err)
+	require.True(t, result.Blocked)
+	require.Contains(t, result.BlockError(), "execution quota exceeded")
+	require.Empty(t, exec.calls)
+}
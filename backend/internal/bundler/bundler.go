@@ -5,7 +5,9 @@ package bundler
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"sort"
@@ -164,6 +166,26 @@ type BundleResult struct {
 	Hash string `json:"hash"`
 	// Metafile contains build metadata (imports, exports, etc.)
 	Metafile *BundleMetafile `json:"metafile,omitempty"`
+	// Timing breaks down how the bundle was produced and how long it took
+	Timing *BundleTiming `json:"timing,omitempty"`
+}
+
+// LinkedJS returns the bundled JavaScript with a comment pointing browsers at
+// the source map served from mapURL
+func (r *BundleResult) LinkedJS(mapURL string) []byte {
+	if len(r.SourceMap) == 0 {
+		return r.OutputJS
+	}
+	return append(append([]byte(nil), r.OutputJS...), "//# sourceMappingURL="+mapURL+"\n"...)
+}
+
+// InlineJS returns the bundled JavaScript with its source map embedded, for
+// pages that inline the bundle in a script tag
+func (r *BundleResult) InlineJS() []byte {
+	if len(r.SourceMap) == 0 {
+		return r.OutputJS
+	}
+	return r.LinkedJS("data:application/json;base64," + base64.StdEncoding.EncodeToString(r.SourceMap))
 }
 
 // BundleError represents a bundling error with source location
@@ -310,15 +332,25 @@ func ComputeFileHash(files map[string]string) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-// ComputeCacheKey generates a cache key from project ID, config, and file hash
+// ComputeCacheKey generates a cache key from project ID, config, and file hash.
+// The first half depends only on the project, so a project's entries can be
+// invalidated by prefix.
 func ComputeCacheKey(projectID uint, config BundleConfig, fileHash string) string {
+	project := sha256.Sum256([]byte(fmt.Sprintf("project:%d", projectID)))
 	hasher := sha256.New()
-	hasher.Write([]byte(fmt.Sprintf("%d", projectID)))
 	hasher.Write([]byte(config.EntryPoint))
 	hasher.Write([]byte(config.Format))
 	hasher.Write([]byte(fmt.Sprintf("%v", config.Minify)))
 	hasher.Write([]byte(fmt.Sprintf("%v", config.SourceMap)))
 	hasher.Write([]byte(config.Framework))
 	hasher.Write([]byte(fileHash))
-	return hex.EncodeToString(hasher.Sum(nil))[:32]
+	return hex.EncodeToString(project[:])[:16] + hex.EncodeToString(hasher.Sum(nil))[:16]
+}
+
+// computeConfigKey hashes every option that affects a build's output
+func computeConfigKey(config BundleConfig) string {
+	config.ProjectPath = ""
+	encoded, _ := json.Marshal(config)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
 }
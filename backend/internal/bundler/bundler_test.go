@@ -199,6 +199,23 @@ func TestBundleCacheInvalidate(t *testing.T) {
 	}
 }
 
+func TestBundleCacheInvalidateByProjectID(t *testing.T) {
+	cache := NewBundleCache(DefaultCacheConfig())
+	defer cache.Close()
+
+	config := BundleConfig{EntryPoint: "src/main.tsx", Framework: "react"}
+	cache.Set(ComputeCacheKey(1, config, "a"), &BundleResult{Success: true})
+	cache.Set(ComputeCacheKey(1, config, "b"), &BundleResult{Success: true})
+	cache.Set(ComputeCacheKey(2, config, "a"), &BundleResult{Success: true})
+
+	if removed := cache.InvalidateByProjectID(1); removed != 2 {
+		t.Fatalf("Expected 2 entries removed for project 1, got %d", removed)
+	}
+	if cache.Get(ComputeCacheKey(2, config, "a")) == nil {
+		t.Error("Expected project 2's entry to remain")
+	}
+}
+
 func TestBundleCacheLRU(t *testing.T) {
 	config := CacheConfig{
 		MaxSize:         3,
@@ -746,3 +763,113 @@ func containsStr(s, substr string) bool {
 	}
 	return false
 }
+
+func TestWorkspaceSyncWritesOnlyChangedFiles(t *testing.T) {
+	store := NewWorkspaceStore(t.TempDir())
+	files := map[string]string{
+		"package.json":  `{"dependencies":{"react":"19"}}`,
+		"src/main.tsx":  "import './App'",
+		"src/App.tsx":   "export default 1",
+		"../escape.txt": "nope",
+	}
+
+	ws, err := store.acquire(7, LockfileHash(files))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	// Leftovers from an earlier process are cleared, dependency caches kept
+	os.MkdirAll(filepath.Join(ws.dir, "node_modules", ".vite"), 0755)
+	os.WriteFile(filepath.Join(ws.dir, "stale.ts"), []byte("old"), 0644)
+	stats, err := ws.sync(files)
+	ws.release()
+	if err != nil || !stats.Fresh || len(stats.Written) != 4 {
+		t.Fatalf("first sync = %+v, %v", stats, err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.dir, "stale.ts")); !os.IsNotExist(err) {
+		t.Error("Expected files from an earlier process to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(ws.dir, "node_modules", ".vite")); err != nil {
+		t.Error("Expected the dependency cache to survive")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(ws.dir), "escape.txt")); !os.IsNotExist(err) {
+		t.Error("A file escaped the workspace")
+	}
+
+	files["src/App.tsx"] = "export default 2"
+	delete(files, "src/main.tsx")
+	ws, _ = store.acquire(7, LockfileHash(files))
+	stats, err = ws.sync(files)
+	ws.release()
+	if err != nil || stats.Fresh || len(stats.Written) != 1 || stats.Written[0] != "src/App.tsx" ||
+		len(stats.Removed) != 1 || stats.Removed[0] != "src/main.tsx" {
+		t.Fatalf("second sync = %+v, %v", stats, err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.dir, "src", "main.tsx")); !os.IsNotExist(err) {
+		t.Error("Expected the deleted file to be removed from the workspace")
+	}
+
+	// A dependency change starts a new workspace
+	files["package.json"] = `{"dependencies":{"react":"19","zod":"3"}}`
+	next, _ := store.acquire(7, LockfileHash(files))
+	next.release()
+	if next == ws || next.dir == ws.dir || len(next.files) != 0 {
+		t.Fatal("Expected a new workspace after a lockfile change")
+	}
+}
+
+func TestWorkspaceReusesBuildWhenChangesDontAffectIt(t *testing.T) {
+	ws := &workspace{
+		lastResult: &BundleResult{Success: true, Metafile: &BundleMetafile{Inputs: map[string]MetafileInput{
+			"src/main.tsx": {},
+			"src/logo.png": {},
+		}}},
+		lastConfig: "config-a",
+	}
+
+	if ws.reusable("config-a", []string{"README.md"}) == nil {
+		t.Error("Expected a README change to reuse the last build")
+	}
+	if ws.reusable("config-a", []string{"src/logo.png"}) != nil {
+		t.Error("Expected a change to a bundle input to rebuild")
+	}
+	if ws.reusable("config-a", []string{"src/NewPage.tsx"}) != nil {
+		t.Error("Expected a new source file to rebuild")
+	}
+	if ws.reusable("config-b", []string{"README.md"}) != nil {
+		t.Error("Expected a config change to rebuild")
+	}
+}
+
+func TestBundleMetrics(t *testing.T) {
+	metrics := NewBundleMetrics()
+	metrics.Record(1, BundleTiming{Mode: ModeFull, TotalMs: 900})
+	metrics.Record(1, BundleTiming{Mode: ModeIncremental, TotalMs: 120})
+	metrics.Record(1, BundleTiming{Mode: ModeIncremental, TotalMs: 80})
+	metrics.Record(2, BundleTiming{Mode: ModeCacheHit, TotalMs: 1})
+
+	snapshot := metrics.Snapshot()
+	if snapshot.Bundles != 4 || snapshot.Modes[ModeIncremental].AvgMs != 100 || snapshot.Modes[ModeIncremental].MaxMs != 120 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+	if recent := metrics.Recent(1); len(recent) != 3 || recent[2].TotalMs != 80 {
+		t.Fatalf("recent = %+v", recent)
+	}
+}
+
+func TestBundleResultSourceMapComments(t *testing.T) {
+	result := &BundleResult{OutputJS: []byte("console.log(1);\n"), SourceMap: []byte(`{"version":3}`)}
+	if got := string(result.LinkedJS("__apex_bundle.js.map")); got != "console.log(1);\n//# sourceMappingURL=__apex_bundle.js.map\n" {
+		t.Fatalf("linked = %q", got)
+	}
+	if got := string(result.InlineJS()); got != "console.log(1);\n//# sourceMappingURL=data:application/json;base64,eyJ2ZXJzaW9uIjozfQ==\n" {
+		t.Fatalf("inline = %q", got)
+	}
+	if string(result.OutputJS) != "console.log(1);\n" {
+		t.Fatal("output was modified")
+	}
+
+	plain := &BundleResult{OutputJS: []byte("x")}
+	if string(plain.LinkedJS("a.map")) != "x" || string(plain.InlineJS()) != "x" {
+		t.Fatal("bundles without a source map should be unchanged")
+	}
+}
@@ -33,6 +33,10 @@ type ESBuildBundler struct {
 	mu sync.RWMutex
 	// tempDir is the base temp directory for bundle operations
 	tempDir string
+	// workspaces keeps per-project build directories between bundles
+	workspaces *WorkspaceStore
+	// metrics records bundle timings
+	metrics *BundleMetrics
 	// supportedFrameworks lists frameworks this bundler supports
 	supportedFrameworks map[string]bool
 }
@@ -40,8 +44,10 @@ type ESBuildBundler struct {
 // NewESBuildBundler creates a new esbuild-based bundler
 func NewESBuildBundler(cache *BundleCache) *ESBuildBundler {
 	bundler := &ESBuildBundler{
-		cache:   cache,
-		tempDir: os.TempDir(),
+		cache:      cache,
+		tempDir:    os.TempDir(),
+		workspaces: NewWorkspaceStore(DefaultWorkspaceRoot()),
+		metrics:    NewBundleMetrics(),
 		supportedFrameworks: map[string]bool{
 			"react":   true,
 			"next":    true,
@@ -215,7 +221,7 @@ func (b *ESBuildBundler) buildArgs(config BundleConfig, outputDir string) ([]str
 
 	// Source maps
 	if config.SourceMap {
-		args = append(args, "--sourcemap=inline")
+		args = append(args, "--sourcemap=external")
 	}
 
 	// Target
@@ -421,6 +427,13 @@ func (b *ESBuildBundler) readOutputFiles(outputDir string, result *BundleResult)
 			jsContent.WriteString("\n")
 		}
 		result.OutputJS = jsContent.Bytes()
+
+		// A source map only lines up with a single output file
+		if len(jsFiles) == 1 {
+			if sourceMap, err := os.ReadFile(jsFiles[0] + ".map"); err == nil {
+				result.SourceMap = sourceMap
+			}
+		}
 	}
 
 	// Read CSS output
@@ -464,44 +477,59 @@ func (b *ESBuildBundler) BundleFromFiles(ctx context.Context, projectID uint, fi
 	start := time.Now()
 	applyPreviewRuntimeEnvDefines(&config)
 
-	// Compute file hash for caching
+	// Compute file hash for caching. The key is taken before the config is
+	// adjusted for the workspace so lookups and stores agree.
 	fileHash := ComputeFileHash(files.Files)
+	cacheKey := ComputeCacheKey(projectID, config, fileHash)
+	configKey := computeConfigKey(config)
 
 	// Check cache
 	if b.cache != nil {
-		cacheKey := ComputeCacheKey(projectID, config, fileHash)
 		if cached := b.cache.Get(cacheKey); cached != nil {
 			log.Printf("[bundler] Cache hit for project %d (hash: %s)", projectID, fileHash[:8])
-			return cached, nil
+			return b.withTiming(projectID, cached, BundleTiming{Mode: ModeCacheHit, TotalMs: time.Since(start).Milliseconds()}), nil
 		}
 	}
 
-	// Create temp directory and write files
-	bundleDir, err := os.MkdirTemp(b.tempDir, fmt.Sprintf("apex-bundle-%d-", projectID))
+	// Sync files into the project's persistent workspace
+	ws, err := b.workspaces.acquire(projectID, LockfileHash(files.Files))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, err
 	}
-	defer os.RemoveAll(bundleDir)
+	defer ws.release()
+	bundleDir := ws.dir
 
-	// Write all files to temp directory
-	for path, content := range files.Files {
-		fullPath := filepath.Join(bundleDir, path)
-		dir := filepath.Dir(fullPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
-		}
-		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
-			return nil, fmt.Errorf("failed to write file %s: %w", path, err)
+	syncStart := time.Now()
+	synced, err := ws.sync(files.Files)
+	if err != nil {
+		return nil, err
+	}
+	timing := BundleTiming{
+		Mode:         ModeIncremental,
+		SyncMs:       time.Since(syncStart).Milliseconds(),
+		FilesWritten: len(synced.Written),
+		FilesRemoved: len(synced.Removed),
+	}
+	if synced.Fresh {
+		timing.Mode = ModeFull
+	} else if previous := ws.reusable(configKey, synced.Changed()); previous != nil {
+		// Only files the bundle doesn't read changed
+		timing.Mode = ModeReused
+		timing.TotalMs = time.Since(start).Milliseconds()
+		if b.cache != nil {
+			b.cache.Set(cacheKey, previous)
 		}
+		return b.withTiming(projectID, previous, timing), nil
 	}
 
-	// Create output directory
+	// Output is rebuilt from scratch so stale chunks are never read back
 	outputDir := filepath.Join(bundleDir, ".apex-output")
+	os.RemoveAll(outputDir)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Update config to use the temp directory
+	// Update config to use the workspace directory
 	config.ProjectPath = bundleDir
 	if err := b.prepareNextPreviewEntry(bundleDir, files, &config); err != nil {
 		return nil, fmt.Errorf("failed to prepare Next.js preview entry: %w", err)
@@ -517,21 +545,23 @@ func (b *ESBuildBundler) BundleFromFiles(ctx context.Context, projectID uint, fi
 	}
 
 	// Run esbuild
+	buildStart := time.Now()
 	result, err := b.runEsbuild(ctx, bundleDir, args)
 	if err != nil {
 		return nil, fmt.Errorf("esbuild execution failed: %w", err)
 	}
-
-	result.Duration = time.Since(start)
+	timing.BuildMs = time.Since(buildStart).Milliseconds()
 
 	// Read output files
 	if result.Success {
 		if err := b.readOutputFiles(outputDir, result); err != nil {
 			log.Printf("[bundler] Warning: failed to read some output files: %v", err)
 		}
+		cssStart := time.Now()
 		if compiledCSS, ok := b.compileTailwindPreviewCSS(ctx, bundleDir, files, result.OutputCSS); ok {
 			result.OutputCSS = compiledCSS
 		}
+		timing.CSSMs = time.Since(cssStart).Milliseconds()
 
 		// Compute output hash
 		hasher := make([]byte, 0)
@@ -541,17 +571,42 @@ func (b *ESBuildBundler) BundleFromFiles(ctx context.Context, projectID uint, fi
 
 		// Cache the result
 		if b.cache != nil {
-			cacheKey := ComputeCacheKey(projectID, config, fileHash)
 			b.cache.Set(cacheKey, result)
 		}
+		ws.lastResult, ws.lastConfig = result, configKey
+	} else {
+		ws.lastResult = nil
 	}
 
-	log.Printf("[bundler] Bundle from files completed for project %d in %v (success: %v, js: %d bytes, css: %d bytes)",
-		projectID, result.Duration, result.Success, len(result.OutputJS), len(result.OutputCSS))
+	result.Duration = time.Since(start)
+	timing.TotalMs = result.Duration.Milliseconds()
+	result = b.withTiming(projectID, result, timing)
+
+	log.Printf("[bundler] Bundle from files completed for project %d in %v (%s, success: %v, js: %d bytes, css: %d bytes)",
+		projectID, result.Duration, timing.Mode, result.Success, len(result.OutputJS), len(result.OutputCSS))
 
 	return result, nil
 }
 
+// withTiming returns a copy of the result carrying the timing and records it
+func (b *ESBuildBundler) withTiming(projectID uint, result *BundleResult, timing BundleTiming) *BundleResult {
+	timing.At = time.Now()
+	b.metrics.Record(projectID, timing)
+	timed := *result
+	timed.Timing = &timing
+	return &timed
+}
+
+// Metrics returns the bundler's timing metrics
+func (b *ESBuildBundler) Metrics() *BundleMetrics {
+	return b.metrics
+}
+
+// Workspaces returns the bundler's persistent workspace store
+func (b *ESBuildBundler) Workspaces() *WorkspaceStore {
+	return b.workspaces
+}
+
 func (b *ESBuildBundler) compileTailwindPreviewCSS(ctx context.Context, bundleDir string, files ProjectFiles, bundledCSS []byte) ([]byte, bool) {
 	inputCSS := string(bundledCSS)
 	if strings.TrimSpace(inputCSS) == "" || !containsTailwindDirective(inputCSS) {
@@ -1074,7 +1129,7 @@ func GenerateHTML(result *BundleResult, config BundleConfig) string {
 			sb.WriteString(`  <script>
 `)
 		}
-		sb.Write(result.OutputJS)
+		sb.Write(result.InlineJS())
 		sb.WriteString(`
   </script>
 `)
@@ -0,0 +1,122 @@
+// Package bundler - Bundle timing metrics
+package bundler
+
+import (
+	"sync"
+	"time"
+)
+
+// Bundle modes, from fastest to slowest
+const (
+	// ModeCacheHit served an identical earlier build from memory
+	ModeCacheHit = "cache_hit"
+	// ModeReused kept the last build because no changed file affects it
+	ModeReused = "reused"
+	// ModeIncremental rebuilt in a warm workspace, writing only changed files
+	ModeIncremental = "incremental"
+	// ModeFull built in a new workspace
+	ModeFull = "full"
+)
+
+// BundleTiming breaks down how long a bundle took
+type BundleTiming struct {
+	// Mode is how the bundle was produced
+	Mode string `json:"mode"`
+	// SyncMs is the time spent writing project files to the workspace
+	SyncMs int64 `json:"sync_ms"`
+	// BuildMs is the time spent in esbuild
+	BuildMs int64 `json:"build_ms"`
+	// CSSMs is the time spent compiling Tailwind CSS
+	CSSMs int64 `json:"css_ms"`
+	// TotalMs is the end-to-end time
+	TotalMs int64 `json:"total_ms"`
+	// FilesWritten is how many files were new or changed
+	FilesWritten int `json:"files_written"`
+	// FilesRemoved is how many files were deleted since the last build
+	FilesRemoved int `json:"files_removed"`
+	// At is when the bundle finished
+	At time.Time `json:"at"`
+}
+
+// ModeStats aggregates the bundles of one mode
+type ModeStats struct {
+	Count   int64 `json:"count"`
+	TotalMs int64 `json:"total_ms"`
+	AvgMs   int64 `json:"avg_ms"`
+	MaxMs   int64 `json:"max_ms"`
+}
+
+// MetricsSnapshot is a point-in-time view of bundle timings
+type MetricsSnapshot struct {
+	// Bundles is the total number of bundles
+	Bundles int64 `json:"bundles"`
+	// Modes aggregates bundles by mode
+	Modes map[string]ModeStats `json:"modes"`
+}
+
+// BundleMetrics records bundle timings overall and per project
+type BundleMetrics struct {
+	// mu protects the metrics
+	mu sync.RWMutex
+	// modes aggregates bundles by mode
+	modes map[string]*ModeStats
+	// recent holds each project's latest bundles, newest last
+	recent map[uint][]BundleTiming
+	// bundles is the total number of bundles
+	bundles int64
+}
+
+// maxRecentTimings is how many bundles are kept per project
+const maxRecentTimings = 20
+
+// NewBundleMetrics creates an empty metrics recorder
+func NewBundleMetrics() *BundleMetrics {
+	return &BundleMetrics{
+		modes:  make(map[string]*ModeStats),
+		recent: make(map[uint][]BundleTiming),
+	}
+}
+
+// Record adds a bundle's timing
+func (m *BundleMetrics) Record(projectID uint, timing BundleTiming) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.modes[timing.Mode]
+	if !ok {
+		stats = &ModeStats{}
+		m.modes[timing.Mode] = stats
+	}
+	stats.Count++
+	stats.TotalMs += timing.TotalMs
+	stats.AvgMs = stats.TotalMs / stats.Count
+	if timing.TotalMs > stats.MaxMs {
+		stats.MaxMs = timing.TotalMs
+	}
+	m.bundles++
+
+	recent := append(m.recent[projectID], timing)
+	if len(recent) > maxRecentTimings {
+		recent = recent[len(recent)-maxRecentTimings:]
+	}
+	m.recent[projectID] = recent
+}
+
+// Snapshot returns the aggregate timings
+func (m *BundleMetrics) Snapshot() MetricsSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := MetricsSnapshot{Bundles: m.bundles, Modes: make(map[string]ModeStats, len(m.modes))}
+	for mode, stats := range m.modes {
+		snapshot.Modes[mode] = *stats
+	}
+	return snapshot
+}
+
+// Recent returns a project's latest bundle timings, newest last
+func (m *BundleMetrics) Recent(projectID uint) []BundleTiming {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]BundleTiming(nil), m.recent[projectID]...)
+}
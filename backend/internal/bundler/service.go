@@ -193,12 +193,19 @@ func (s *Service) NeedsBundling(ctx context.Context, projectID uint) (bool, stri
 	return false, ""
 }
 
-// InvalidateCache invalidates the cache for a project
+// InvalidateCache invalidates the cache for a project and drops its
+// workspace, so the next bundle is a full build
 func (s *Service) InvalidateCache(projectID uint) {
 	s.cache.InvalidateByProjectID(projectID)
+	s.bundler.Workspaces().Invalidate(projectID)
 	log.Printf("[bundler-service] Cache invalidated for project %d", projectID)
 }
 
+// ProjectTimings returns a project's latest bundle timings, newest last
+func (s *Service) ProjectTimings(projectID uint) []BundleTiming {
+	return s.bundler.Metrics().Recent(projectID)
+}
+
 // GetCacheStats returns cache statistics
 func (s *Service) GetCacheStats() CacheStats {
 	return s.cache.Stats()
@@ -436,11 +443,11 @@ func (s *Service) injectBundleIntoHTML(html string, result *BundleResult, config
 		if config.Format == "esm" {
 			scriptTag = fmt.Sprintf(`<script type="module">
 %s
-</script>`, string(result.OutputJS))
+</script>`, string(result.InlineJS()))
 		} else {
 			scriptTag = fmt.Sprintf(`<script>
 %s
-</script>`, string(result.OutputJS))
+</script>`, string(result.InlineJS()))
 		}
 
 		if strings.Contains(html, "</body>") {
@@ -554,6 +561,7 @@ type BuildResponse struct {
 	Hash       string        `json:"hash,omitempty"`
 	JSSize     int           `json:"js_size,omitempty"`
 	CSSSize    int           `json:"css_size,omitempty"`
+	Timing     *BundleTiming `json:"timing,omitempty"`
 }
 
 // HandleBuildRequest processes a build request
@@ -579,6 +587,7 @@ func (s *Service) HandleBuildRequest(ctx context.Context, req BuildRequest) (*Bu
 		Hash:       result.Hash,
 		JSSize:     len(result.OutputJS),
 		CSSSize:    len(result.OutputCSS),
+		Timing:     result.Timing,
 	}, nil
 }
 
@@ -598,10 +607,12 @@ func (s *Service) Shutdown() {
 
 // Status returns the current status of the bundler service
 type ServiceStatus struct {
-	Available  bool       `json:"available"`
-	Version    string     `json:"version"`
-	CacheStats CacheStats `json:"cache_stats"`
-	LastError  string     `json:"last_error,omitempty"`
+	Available  bool            `json:"available"`
+	Version    string          `json:"version"`
+	CacheStats CacheStats      `json:"cache_stats"`
+	Metrics    MetricsSnapshot `json:"metrics"`
+	Workspaces int             `json:"workspaces"`
+	LastError  string          `json:"last_error,omitempty"`
 }
 
 func (s *Service) Status() ServiceStatus {
@@ -609,5 +620,7 @@ func (s *Service) Status() ServiceStatus {
 		Available:  s.IsAvailable(),
 		Version:    s.GetVersion(),
 		CacheStats: s.GetCacheStats(),
+		Metrics:    s.bundler.Metrics().Snapshot(),
+		Workspaces: s.bundler.Workspaces().Count(),
 	}
 }
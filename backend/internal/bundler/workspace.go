@@ -0,0 +1,290 @@
+// Package bundler - Persistent bundle workspaces for incremental rebuilds
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockfileNames are the files whose content decides a project's dependency set
+var lockfileNames = []string{"package.json", "package-lock.json", "pnpm-lock.yaml", "yarn.lock", "bun.lockb", "bun.lock"}
+
+// workspaceCacheDirs survive when a workspace is reset. They hold the
+// esbuild, Vite and Tailwind caches, which only depend on the lockfile.
+var workspaceCacheDirs = map[string]bool{"node_modules": true}
+
+// LockfileHash hashes the project's package manifest and lockfiles. Bundle
+// workspaces are keyed by it, so a dependency change starts a clean one.
+func LockfileHash(files map[string]string) string {
+	hasher := sha256.New()
+	for _, name := range lockfileNames {
+		for _, candidate := range []string{name, "/" + name} {
+			if content, ok := files[candidate]; ok {
+				hasher.Write([]byte(name))
+				hasher.Write([]byte(content))
+				break
+			}
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// WorkspaceStore keeps one build directory per project on disk. Rebuilds
+// write only the files that changed since the last build, and cache
+// directories inside the workspace persist between builds and restarts.
+type WorkspaceStore struct {
+	// root is the directory workspaces are created in
+	root string
+	// maxIdle is how long an unused workspace is kept
+	maxIdle time.Duration
+	// maxWorkspaces caps how many workspaces are kept on disk
+	maxWorkspaces int
+	// mu protects workspaces
+	mu sync.Mutex
+	// workspaces maps project IDs to their current workspace
+	workspaces map[uint]*workspace
+}
+
+// workspace is a project's build directory and what was last written to it
+type workspace struct {
+	// mu is held for the duration of a build
+	mu sync.Mutex
+	// dir is the workspace directory
+	dir string
+	// lockHash is the dependency set the workspace was created for
+	lockHash string
+	// files maps written paths to their content hash
+	files map[string]string
+	// lastUsed is when the workspace was last acquired
+	lastUsed time.Time
+	// lastResult is the last successful build and the config key it used
+	lastResult *BundleResult
+	lastConfig string
+}
+
+// SyncStats describes what a workspace sync wrote
+type SyncStats struct {
+	// Fresh is true when the workspace had no files from an earlier build
+	Fresh bool
+	// Written lists files that were new or changed
+	Written []string
+	// Removed lists files deleted from the project since the last build
+	Removed []string
+}
+
+// Changed returns every path that differs from the last build
+func (s SyncStats) Changed() []string {
+	changed := make([]string, 0, len(s.Written)+len(s.Removed))
+	changed = append(changed, s.Written...)
+	return append(changed, s.Removed...)
+}
+
+// DefaultWorkspaceRoot returns APEX_BUNDLE_CACHE_DIR, or a directory under
+// the system temp dir
+func DefaultWorkspaceRoot() string {
+	if dir := strings.TrimSpace(os.Getenv("APEX_BUNDLE_CACHE_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "apex-bundle-cache")
+}
+
+// NewWorkspaceStore creates a workspace store rooted at the given directory
+func NewWorkspaceStore(root string) *WorkspaceStore {
+	return &WorkspaceStore{
+		root:          root,
+		maxIdle:       30 * time.Minute,
+		maxWorkspaces: 64,
+		workspaces:    make(map[uint]*workspace),
+	}
+}
+
+// acquire returns the project's workspace for the lockfile hash, locked for
+// a build. A workspace for a different lockfile hash is deleted.
+func (s *WorkspaceStore) acquire(projectID uint, lockHash string) (*workspace, error) {
+	s.mu.Lock()
+	now := time.Now()
+	s.pruneLocked(now, projectID)
+
+	ws, ok := s.workspaces[projectID]
+	if ok && ws.lockHash != lockHash {
+		delete(s.workspaces, projectID)
+		stale := ws
+		go func() {
+			stale.mu.Lock()
+			defer stale.mu.Unlock()
+			os.RemoveAll(stale.dir)
+		}()
+		ok = false
+	}
+	if !ok {
+		ws = &workspace{
+			dir:      filepath.Join(s.root, fmt.Sprintf("project-%d-%s", projectID, lockHash[:12])),
+			lockHash: lockHash,
+			files:    make(map[string]string),
+		}
+		s.workspaces[projectID] = ws
+	}
+	ws.lastUsed = now
+	s.mu.Unlock()
+
+	ws.mu.Lock()
+	if err := os.MkdirAll(ws.dir, 0755); err != nil {
+		ws.mu.Unlock()
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return ws, nil
+}
+
+// release unlocks a workspace after a build
+func (ws *workspace) release() {
+	ws.mu.Unlock()
+}
+
+// Invalidate drops the project's workspace so the next build starts clean
+func (s *WorkspaceStore) Invalidate(projectID uint) {
+	s.mu.Lock()
+	ws, ok := s.workspaces[projectID]
+	delete(s.workspaces, projectID)
+	s.mu.Unlock()
+	if ok {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		os.RemoveAll(ws.dir)
+	}
+}
+
+// Count returns how many workspaces are in use
+func (s *WorkspaceStore) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.workspaces)
+}
+
+// pruneLocked deletes idle workspaces, and the least recently used ones
+// over the cap, except the one being acquired (must hold s.mu)
+func (s *WorkspaceStore) pruneLocked(now time.Time, keep uint) {
+	type entry struct {
+		projectID uint
+		lastUsed  time.Time
+	}
+	entries := make([]entry, 0, len(s.workspaces))
+	for projectID, ws := range s.workspaces {
+		if projectID != keep {
+			entries = append(entries, entry{projectID, ws.lastUsed})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].lastUsed.Before(entries[j].lastUsed) })
+
+	over := len(s.workspaces) - s.maxWorkspaces + 1
+	for i, e := range entries {
+		if i >= over && now.Sub(e.lastUsed) < s.maxIdle {
+			break
+		}
+		ws := s.workspaces[e.projectID]
+		delete(s.workspaces, e.projectID)
+		go func() {
+			ws.mu.Lock()
+			defer ws.mu.Unlock()
+			os.RemoveAll(ws.dir)
+		}()
+	}
+}
+
+// sync brings the workspace in line with the project files, writing only
+// files that changed. The first sync of a workspace clears anything left
+// from an earlier process except the dependency caches.
+func (ws *workspace) sync(files map[string]string) (SyncStats, error) {
+	stats := SyncStats{Fresh: len(ws.files) == 0}
+	if stats.Fresh {
+		entries, _ := os.ReadDir(ws.dir)
+		for _, entry := range entries {
+			if !workspaceCacheDirs[entry.Name()] {
+				os.RemoveAll(filepath.Join(ws.dir, entry.Name()))
+			}
+		}
+	}
+
+	for path, content := range files {
+		fullPath, ok := ws.path(path)
+		if !ok {
+			continue
+		}
+		sum := sha256.Sum256([]byte(content))
+		hash := hex.EncodeToString(sum[:])
+		if ws.files[path] == hash {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return stats, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(fullPath), err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return stats, fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+		ws.files[path] = hash
+		stats.Written = append(stats.Written, path)
+	}
+
+	for path := range ws.files {
+		if _, ok := files[path]; ok {
+			continue
+		}
+		if fullPath, ok := ws.path(path); ok {
+			os.Remove(fullPath)
+		}
+		delete(ws.files, path)
+		stats.Removed = append(stats.Removed, path)
+	}
+	sort.Strings(stats.Written)
+	sort.Strings(stats.Removed)
+	return stats, nil
+}
+
+// path resolves a project path inside the workspace, rejecting paths that
+// would escape it
+func (ws *workspace) path(projectPath string) (string, bool) {
+	cleaned := filepath.Clean("/" + filepath.FromSlash(projectPath))
+	if cleaned == string(filepath.Separator) {
+		return "", false
+	}
+	return filepath.Join(ws.dir, cleaned), true
+}
+
+// reusable returns the last build when none of the changed files could
+// affect it: none was an input of that build and none is a source, style
+// or config file the next build could start importing
+func (ws *workspace) reusable(configKey string, changed []string) *BundleResult {
+	if ws.lastResult == nil || ws.lastConfig != configKey {
+		return nil
+	}
+	inputs := map[string]bool{}
+	if ws.lastResult.Metafile != nil {
+		for input := range ws.lastResult.Metafile.Inputs {
+			inputs[strings.TrimPrefix(filepath.ToSlash(input), "./")] = true
+		}
+	}
+	for _, path := range changed {
+		normalized := strings.TrimPrefix(filepath.ToSlash(path), "/")
+		if inputs[normalized] || affectsBundle(normalized) {
+			return nil
+		}
+	}
+	return ws.lastResult
+}
+
+// affectsBundle reports whether a file of this type can change a bundle
+// without being one of its inputs yet
+func affectsBundle(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs", ".mts", ".cts",
+		".css", ".scss", ".sass", ".less", ".json", ".html", ".vue", ".svelte":
+		return true
+	}
+	return false
+}
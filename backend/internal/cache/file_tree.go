@@ -0,0 +1,158 @@
+// Package cache - File tree versioning
+// Bumps a project's tree version whenever a row in the files table changes
+package cache
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// fileTreeTTL is how long a versioned file listing is kept. Mutations
+	// switch readers to a new version, so this only bounds memory use.
+	fileTreeTTL = 12 * time.Hour
+
+	// fileTreeRebumpDelay is how long after a mutation made inside a
+	// transaction the version is bumped again. A listing loaded before the
+	// transaction committed may have been cached under the first bump.
+	fileTreeRebumpDelay = 2 * time.Second
+
+	fileTreeProjectsKey = "apex:file_tree_projects"
+)
+
+// WatchFileMutations registers GORM callbacks that bump the tree version of
+// every project whose files are created, updated or deleted through db, so
+// agents, git sync, restore points and the editor all invalidate listings
+// without calling the cache themselves.
+func (fc *FileCache) WatchFileMutations(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("apex:file_tree_create", fc.afterFileMutation); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("apex:file_tree_collect_update", fc.collectFileProjects); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("apex:file_tree_update", fc.afterFileMutation); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("apex:file_tree_collect_delete", fc.collectFileProjects); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("apex:file_tree_delete", fc.afterFileMutation)
+}
+
+// collectFileProjects records, before an update or delete runs, which
+// projects own the rows its conditions match.
+func (fc *FileCache) collectFileProjects(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Table != "files" {
+		return
+	}
+	where, ok := db.Statement.Clauses["WHERE"].Expression.(clause.Where)
+	if !ok || len(where.Exprs) == 0 {
+		return
+	}
+	query := db.Session(&gorm.Session{NewDB: true, SkipHooks: true, Context: db.Statement.Context})
+	if db.Statement.Schema != nil {
+		query = query.Model(reflect.New(db.Statement.Schema.ModelType).Interface())
+	} else {
+		query = query.Table("files")
+	}
+	var projectIDs []uint
+	if err := query.Unscoped().Clauses(where).Distinct().Pluck("project_id", &projectIDs).Error; err != nil {
+		log.Printf("file tree cache: resolve projects for %s: %v", db.Statement.Table, err)
+		return
+	}
+	db.InstanceSet(fileTreeProjectsKey, projectIDs)
+}
+
+func (fc *FileCache) afterFileMutation(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Table != "files" || db.Statement.RowsAffected == 0 {
+		return
+	}
+	seen := make(map[uint]bool)
+	if collected, ok := db.InstanceGet(fileTreeProjectsKey); ok {
+		for _, id := range collected.([]uint) {
+			seen[id] = true
+		}
+	}
+	for _, id := range statementProjectIDs(db) {
+		seen[id] = true
+	}
+	_, inTx := db.Statement.ConnPool.(gorm.TxCommitter)
+	for projectID := range seen {
+		if _, err := fc.BumpTreeVersion(db.Statement.Context, projectID); err != nil {
+			log.Printf("file tree cache: bump project %d: %v", projectID, err)
+		}
+		if inTx {
+			fc.scheduleRebump(projectID)
+		}
+	}
+}
+
+// scheduleRebump bumps the project's version again shortly after, coalescing
+// mutations made in quick succession.
+func (fc *FileCache) scheduleRebump(projectID uint) {
+	fc.rebumpMu.Lock()
+	defer fc.rebumpMu.Unlock()
+	if timer, ok := fc.rebumps[projectID]; ok {
+		timer.Reset(fileTreeRebumpDelay)
+		return
+	}
+	fc.rebumps[projectID] = time.AfterFunc(fileTreeRebumpDelay, func() {
+		fc.rebumpMu.Lock()
+		delete(fc.rebumps, projectID)
+		fc.rebumpMu.Unlock()
+		if _, err := fc.BumpTreeVersion(context.Background(), projectID); err != nil {
+			log.Printf("file tree cache: bump project %d: %v", projectID, err)
+		}
+	})
+}
+
+// statementProjectIDs reads ProjectID from the records a statement wrote.
+func statementProjectIDs(db *gorm.DB) []uint {
+	if db.Statement.Schema == nil {
+		return nil
+	}
+	field := db.Statement.Schema.LookUpField("ProjectID")
+	if field == nil {
+		return nil
+	}
+	var ids []uint
+	add := func(rv reflect.Value) {
+		rv = reflect.Indirect(rv)
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		if value, zero := field.ValueOf(db.Statement.Context, rv); !zero {
+			if id, ok := value.(uint); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			add(rv.Index(i))
+		}
+	case reflect.Struct:
+		add(rv)
+	}
+	return ids
+}
+
+var treeVersionSeq uint64
+
+// newTreeVersion returns a version token unique across instances sharing the
+// cache.
+func newTreeVersion() string {
+	seq := atomic.AddUint64(&treeVersionSeq, 1)
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(seq, 36)
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type treeTestFile struct {
+	ID        uint `gorm:"primarykey"`
+	ProjectID uint
+	Path      string
+	DeletedAt gorm.DeletedAt
+}
+
+func (treeTestFile) TableName() string { return "files" }
+
+func setupFileTreeTest(t *testing.T) (*FileCache, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&treeTestFile{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	fc := NewFileCache(NewRedisCache(DefaultCacheConfig()))
+	if err := fc.WatchFileMutations(db); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	return fc, db
+}
+
+func TestFileCacheServesListUntilTreeVersionChanges(t *testing.T) {
+	fc, _ := setupFileTreeTest(t)
+	ctx := context.Background()
+
+	version, err := fc.TreeVersion(ctx, 1)
+	if err != nil {
+		t.Fatalf("tree version: %v", err)
+	}
+	if err := fc.SetFileList(ctx, 1, version, &CachedFileList{Files: []CachedFile{{ID: 1, Path: "main.go"}}, Total: 1}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	list, err := fc.GetFileList(ctx, 1)
+	if err != nil || list.Total != 1 || list.TreeVersion != version {
+		t.Fatalf("cached list = %+v, %v", list, err)
+	}
+
+	if err := fc.InvalidateFileList(ctx, 1); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if _, err := fc.GetFileList(ctx, 1); err != ErrCacheMiss {
+		t.Fatalf("after invalidation err = %v, want cache miss", err)
+	}
+}
+
+func TestFileMutationsBumpTreeVersion(t *testing.T) {
+	fc, db := setupFileTreeTest(t)
+	ctx := context.Background()
+
+	versionOf := func(projectID uint) string {
+		t.Helper()
+		version, err := fc.TreeVersion(ctx, projectID)
+		if err != nil {
+			t.Fatalf("tree version: %v", err)
+		}
+		return version
+	}
+	expectBump := func(name string, projectID uint, mutate func() error) {
+		t.Helper()
+		before := versionOf(projectID)
+		other := versionOf(99)
+		if err := mutate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if versionOf(projectID) == before {
+			t.Fatalf("%s did not bump project %d", name, projectID)
+		}
+		if versionOf(99) != other {
+			t.Fatalf("%s bumped an unrelated project", name)
+		}
+	}
+
+	file := &treeTestFile{ProjectID: 1, Path: "main.go"}
+	expectBump("create", 1, func() error { return db.Create(file).Error })
+	expectBump("save", 1, func() error {
+		file.Path = "cmd/main.go"
+		return db.Save(file).Error
+	})
+	expectBump("update by id", 1, func() error {
+		return db.Model(&treeTestFile{}).Where("id = ?", file.ID).Update("path", "app.go").Error
+	})
+	expectBump("delete by id", 1, func() error { return db.Delete(&treeTestFile{}, file.ID).Error })
+	expectBump("restore", 1, func() error {
+		return db.Unscoped().Model(&treeTestFile{}).Where("id = ?", file.ID).Update("deleted_at", nil).Error
+	})
+	expectBump("transaction", 2, func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&[]treeTestFile{{ProjectID: 2, Path: "a.go"}, {ProjectID: 2, Path: "b.go"}}).Error
+		})
+	})
+
+	before := versionOf(1)
+	if err := db.Model(&treeTestFile{}).Where("id = ?", 12345).Update("path", "nope").Error; err != nil {
+		t.Fatalf("no-op update: %v", err)
+	}
+	if versionOf(1) != before {
+		t.Fatal("update matching no rows bumped the tree version")
+	}
+}
@@ -5,6 +5,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 )
 
@@ -22,6 +23,7 @@ type CachedProject struct {
 	Language     string                 `json:"language"`
 	Framework    string                 `json:"framework"`
 	OwnerID      uint                   `json:"owner_id"`
+	OrgID        *uint                  `json:"organization_id,omitempty"`
 	IsPublic     bool                   `json:"is_public"`
 	IsArchived   bool                   `json:"is_archived"`
 	FileCount    int                    `json:"file_count"`
@@ -126,10 +128,15 @@ func (pc *ProjectCache) GetOrLoadProjectList(
 	return list, nil
 }
 
-// FileCache provides caching for file listings
+// FileCache provides caching for file listings. Listings are stored under
+// the project's current tree version, which is bumped on every file mutation,
+// so entries never go stale and can live for hours.
 type FileCache struct {
 	cache *RedisCache
 	ttl   time.Duration
+
+	rebumpMu sync.Mutex
+	rebumps  map[uint]*time.Timer
 }
 
 // CachedFile represents a file in the cache
@@ -147,43 +154,70 @@ type CachedFile struct {
 
 // CachedFileList represents a list of files
 type CachedFileList struct {
-	Files    []CachedFile `json:"files"`
-	Total    int          `json:"total"`
-	CachedAt time.Time    `json:"cached_at"`
+	Files       []CachedFile `json:"files"`
+	Total       int          `json:"total"`
+	TreeVersion string       `json:"tree_version"`
+	CachedAt    time.Time    `json:"cached_at"`
 }
 
 // NewFileCache creates a new file cache instance
 func NewFileCache(cache *RedisCache) *FileCache {
 	return &FileCache{
-		cache: cache,
-		ttl:   60 * time.Second,
+		cache:   cache,
+		ttl:     fileTreeTTL,
+		rebumps: make(map[uint]*time.Timer),
+	}
+}
+
+// TreeVersion returns the project's current tree version, starting a new one
+// when none is stored.
+func (fc *FileCache) TreeVersion(ctx context.Context, projectID uint) (string, error) {
+	key := FileTreeVersionCacheKey(projectID)
+	if data, err := fc.cache.Get(ctx, key); err == nil && len(data) > 0 {
+		return string(data), nil
+	}
+	return fc.BumpTreeVersion(ctx, projectID)
+}
+
+// BumpTreeVersion starts a new tree version for a project. Listings cached
+// under earlier versions are no longer read and expire on their own.
+func (fc *FileCache) BumpTreeVersion(ctx context.Context, projectID uint) (string, error) {
+	version := newTreeVersion()
+	// The version outlives the listings stored under it.
+	if err := fc.cache.Set(ctx, FileTreeVersionCacheKey(projectID), []byte(version), 2*fc.ttl); err != nil {
+		return "", err
 	}
+	return version, nil
 }
 
-// GetFileList retrieves a cached file list for a project
+// GetFileList retrieves the cached file list for a project's current tree
+// version
 func (fc *FileCache) GetFileList(ctx context.Context, projectID uint) (*CachedFileList, error) {
-	key := FileListCacheKey(projectID)
+	version, err := fc.TreeVersion(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
 
 	var result CachedFileList
-	err := fc.cache.GetJSON(ctx, key, &result)
-	if err != nil {
+	if err := fc.cache.GetJSON(ctx, FileListCacheKey(projectID, version), &result); err != nil {
 		return nil, err
 	}
 
 	return &result, nil
 }
 
-// SetFileList caches a file list for a project
-func (fc *FileCache) SetFileList(ctx context.Context, projectID uint, list *CachedFileList) error {
-	key := FileListCacheKey(projectID)
+// SetFileList caches a file list under the tree version read before the list
+// was loaded, so a mutation made meanwhile is not hidden behind it.
+func (fc *FileCache) SetFileList(ctx context.Context, projectID uint, version string, list *CachedFileList) error {
+	list.TreeVersion = version
 	list.CachedAt = time.Now()
-	return fc.cache.SetJSON(ctx, key, list, fc.ttl)
+	return fc.cache.SetJSON(ctx, FileListCacheKey(projectID, version), list, fc.ttl)
 }
 
 // InvalidateFileList invalidates the file list cache for a project
 func (fc *FileCache) InvalidateFileList(ctx context.Context, projectID uint) error {
-	key := FileListCacheKey(projectID)
-	return fc.cache.Delete(ctx, key)
+	_, err := fc.BumpTreeVersion(ctx, projectID)
+	return err
 }
 
 // SessionCache provides caching for user sessions
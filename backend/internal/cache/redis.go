@@ -581,9 +581,16 @@ func UserSessionCacheKey(userID uint) string {
 	return fmt.Sprintf("session:user:%d", userID)
 }
 
-// FileListCacheKey returns the cache key for file listings
-func FileListCacheKey(projectID uint) string {
-	return fmt.Sprintf("files:project:%d", projectID)
+// FileListCacheKey returns the cache key for a project's file listing at a
+// tree version
+func FileListCacheKey(projectID uint, treeVersion string) string {
+	return fmt.Sprintf("files:project:%d:tree:%s", projectID, treeVersion)
+}
+
+// FileTreeVersionCacheKey returns the cache key holding a project's current
+// tree version
+func FileTreeVersionCacheKey(projectID uint) string {
+	return fmt.Sprintf("files:project:%d:version", projectID)
 }
 
 // UserProjectsPattern returns the pattern for all user's project cache entries
@@ -0,0 +1,99 @@
+package codestandards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrganizationStandards stores one organization's policy.
+type OrganizationStandards struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex"`
+	Policy         Policy    `json:"policy" gorm:"serializer:json;type:text"`
+	UpdatedBy      uint      `json:"updated_by"`
+}
+
+// TableName pins the table name.
+func (OrganizationStandards) TableName() string {
+	return "organization_coding_standards"
+}
+
+// AutoMigrate creates the coding standards table.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&OrganizationStandards{})
+}
+
+// Service reads and writes organization coding standards.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new coding standards Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Get returns an organization's policy, or an empty policy if none is set.
+func (s *Service) Get(ctx context.Context, orgID uint) (*OrganizationStandards, error) {
+	var standards OrganizationStandards
+	err := s.db.WithContext(ctx).Where("organization_id = ?", orgID).First(&standards).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &OrganizationStandards{OrganizationID: orgID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("codestandards: load failed: %w", err)
+	}
+	return &standards, nil
+}
+
+// Save validates and stores an organization's policy. Callers check that the
+// user may manage the organization.
+func (s *Service) Save(ctx context.Context, orgID, userID uint, policy Policy) (*OrganizationStandards, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	policy.Sources = nil
+	standards, err := s.Get(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	standards.Policy = policy
+	standards.UpdatedBy = userID
+	if err := s.db.WithContext(ctx).Save(standards).Error; err != nil {
+		return nil, fmt.Errorf("codestandards: save failed: %w", err)
+	}
+	return standards, nil
+}
+
+// ForUser merges the policies of every organization the user is an active
+// member of. It returns nil when none apply.
+func (s *Service) ForUser(ctx context.Context, userID uint) (*Policy, error) {
+	var rows []struct {
+		OrganizationStandards
+		OrganizationName string
+	}
+	err := s.db.WithContext(ctx).
+		Table("organization_coding_standards AS s").
+		Select("s.*, o.name AS organization_name").
+		Joins("JOIN organization_members m ON m.organization_id = s.organization_id AND m.deleted_at IS NULL").
+		Joins("JOIN organizations o ON o.id = s.organization_id AND o.deleted_at IS NULL").
+		Where("m.user_id = ? AND m.status = ?", userID, "active").
+		Order("s.organization_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("codestandards: load member policies failed: %w", err)
+	}
+	policies := make([]*Policy, 0, len(rows))
+	for idx := range rows {
+		policy := rows[idx].Policy
+		policy.Sources = []string{rows[idx].OrganizationName}
+		policies = append(policies, &policy)
+	}
+	return Merge(policies...), nil
+}
@@ -0,0 +1,472 @@
+// Package codestandards holds organization coding standards: a style guide,
+// banned libraries and required or forbidden code patterns. Standards are
+// injected into agent system prompts for org members' builds and checked
+// deterministically against the generated files during the review phase.
+package codestandards
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Violation kinds.
+const (
+	KindBannedLibrary    = "banned_library"
+	KindRequiredPattern  = "required_pattern"
+	KindForbiddenPattern = "forbidden_pattern"
+)
+
+const (
+	maxStyleGuideBytes = 8 * 1024
+	maxBannedLibraries = 100
+	maxPatternRules    = 50
+	maxPatternLength   = 500
+	// Violations past this many are dropped; the count is still reported.
+	maxViolations = 200
+)
+
+// PatternRule is a regular expression that must (required) or must not
+// (forbidden) appear in files matching Files.
+type PatternRule struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"`            // Go regexp syntax
+	Files    string `json:"files,omitempty"`    // glob on the path or base name, e.g. "*.ts" or "server/*.js"; empty means every file
+	Message  string `json:"message,omitempty"`  // shown with each violation
+	Severity string `json:"severity,omitempty"` // error (default) or warning
+}
+
+// Policy is one organization's coding standards.
+type Policy struct {
+	StyleGuide        string        `json:"style_guide,omitempty"`
+	BannedLibraries   []string      `json:"banned_libraries,omitempty"`
+	RequiredPatterns  []PatternRule `json:"required_patterns,omitempty"`
+	ForbiddenPatterns []PatternRule `json:"forbidden_patterns,omitempty"`
+	// Sources names the organizations a merged policy came from.
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Empty reports whether the policy has nothing to inject or check.
+func (p *Policy) Empty() bool {
+	return p == nil || (strings.TrimSpace(p.StyleGuide) == "" && len(p.BannedLibraries) == 0 &&
+		len(p.RequiredPatterns) == 0 && len(p.ForbiddenPatterns) == 0)
+}
+
+// File is a generated file to check.
+type File struct {
+	Path    string
+	Content string
+}
+
+// Violation is one place the generated code breaks the standards.
+type Violation struct {
+	Kind     string `json:"kind"`
+	Rule     string `json:"rule"`
+	Path     string `json:"path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// Report is the result of checking a build against a policy.
+type Report struct {
+	Violations []Violation `json:"violations"`
+	Errors     int         `json:"errors"`
+	Warnings   int         `json:"warnings"`
+	Truncated  bool        `json:"truncated,omitempty"`
+}
+
+// Passed reports whether there are no error-severity violations.
+func (r *Report) Passed() bool {
+	return r == nil || r.Errors == 0
+}
+
+// Validate normalizes the policy and rejects invalid patterns or oversized
+// fields.
+func (p *Policy) Validate() error {
+	p.StyleGuide = strings.TrimSpace(p.StyleGuide)
+	if len(p.StyleGuide) > maxStyleGuideBytes {
+		return fmt.Errorf("style guide must be at most %d bytes", maxStyleGuideBytes)
+	}
+	libraries := make([]string, 0, len(p.BannedLibraries))
+	seen := make(map[string]bool)
+	for _, library := range p.BannedLibraries {
+		library = strings.ToLower(strings.TrimSpace(library))
+		if library == "" || seen[library] {
+			continue
+		}
+		seen[library] = true
+		libraries = append(libraries, library)
+	}
+	if len(libraries) > maxBannedLibraries {
+		return fmt.Errorf("at most %d banned libraries are allowed", maxBannedLibraries)
+	}
+	p.BannedLibraries = libraries
+	if len(p.RequiredPatterns)+len(p.ForbiddenPatterns) > maxPatternRules {
+		return fmt.Errorf("at most %d pattern rules are allowed", maxPatternRules)
+	}
+	for _, rules := range [][]PatternRule{p.RequiredPatterns, p.ForbiddenPatterns} {
+		for idx := range rules {
+			rule := &rules[idx]
+			rule.Name = strings.TrimSpace(rule.Name)
+			if rule.Name == "" {
+				return fmt.Errorf("every pattern rule needs a name")
+			}
+			if rule.Pattern == "" || len(rule.Pattern) > maxPatternLength {
+				return fmt.Errorf("rule %q: pattern must be 1-%d characters", rule.Name, maxPatternLength)
+			}
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("rule %q: invalid pattern: %v", rule.Name, err)
+			}
+			if _, err := path.Match(rule.Files, ""); err != nil {
+				return fmt.Errorf("rule %q: invalid files glob: %v", rule.Name, err)
+			}
+			switch rule.Severity {
+			case "":
+				rule.Severity = SeverityError
+			case SeverityError, SeverityWarning:
+			default:
+				return fmt.Errorf("rule %q: severity must be %q or %q", rule.Name, SeverityError, SeverityWarning)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge combines policies, e.g. from every organization a user belongs to.
+// Style guides are concatenated and rules are unioned.
+func Merge(policies ...*Policy) *Policy {
+	merged := &Policy{}
+	var guides []string
+	seenLibrary := make(map[string]bool)
+	for _, policy := range policies {
+		if policy.Empty() {
+			continue
+		}
+		if guide := strings.TrimSpace(policy.StyleGuide); guide != "" {
+			guides = append(guides, guide)
+		}
+		for _, library := range policy.BannedLibraries {
+			if !seenLibrary[library] {
+				seenLibrary[library] = true
+				merged.BannedLibraries = append(merged.BannedLibraries, library)
+			}
+		}
+		merged.RequiredPatterns = append(merged.RequiredPatterns, policy.RequiredPatterns...)
+		merged.ForbiddenPatterns = append(merged.ForbiddenPatterns, policy.ForbiddenPatterns...)
+		merged.Sources = append(merged.Sources, policy.Sources...)
+	}
+	merged.StyleGuide = strings.Join(guides, "\n\n")
+	if merged.Empty() {
+		return nil
+	}
+	return merged
+}
+
+// PromptContext renders the policy for an agent system prompt.
+func PromptContext(p *Policy) string {
+	if p.Empty() {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nORGANIZATION CODING STANDARDS (mandatory; generated code is checked against these before delivery):")
+	if p.StyleGuide != "" {
+		sb.WriteString("\nStyle guide:\n" + p.StyleGuide)
+	}
+	if len(p.BannedLibraries) > 0 {
+		sb.WriteString("\nBanned libraries (never import or add as dependencies): " + strings.Join(p.BannedLibraries, ", "))
+	}
+	writeRules := func(title string, rules []PatternRule) {
+		if len(rules) == 0 {
+			return
+		}
+		sb.WriteString("\n" + title)
+		for _, rule := range rules {
+			line := "\n- " + rule.Name
+			if rule.Files != "" {
+				line += " (files: " + rule.Files + ")"
+			}
+			if rule.Message != "" {
+				line += ": " + rule.Message
+			}
+			sb.WriteString(line)
+		}
+	}
+	writeRules("Required patterns:", p.RequiredPatterns)
+	writeRules("Forbidden patterns:", p.ForbiddenPatterns)
+	return sb.String()
+}
+
+func ruleMatchesFile(rule PatternRule, filePath string) bool {
+	if rule.Files == "" {
+		return true
+	}
+	if ok, _ := path.Match(rule.Files, filePath); ok {
+		return true
+	}
+	ok, _ := path.Match(rule.Files, path.Base(filePath))
+	return ok
+}
+
+// Check runs the policy against the files. It is deterministic: the same
+// files and policy always give the same report, in path order.
+func Check(p *Policy, files []File) *Report {
+	report := &Report{Violations: []Violation{}}
+	if p.Empty() {
+		return report
+	}
+	sorted := append([]File(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	add := func(v Violation) {
+		if v.Severity == SeverityWarning {
+			report.Warnings++
+		} else {
+			v.Severity = SeverityError
+			report.Errors++
+		}
+		if len(report.Violations) >= maxViolations {
+			report.Truncated = true
+			return
+		}
+		report.Violations = append(report.Violations, v)
+	}
+
+	banned := make(map[string]bool, len(p.BannedLibraries))
+	for _, library := range p.BannedLibraries {
+		banned[library] = true
+	}
+	for _, file := range sorted {
+		for _, hit := range bannedLibraryHits(file, banned) {
+			add(Violation{
+				Kind:    KindBannedLibrary,
+				Rule:    hit.library,
+				Path:    file.Path,
+				Line:    hit.line,
+				Message: fmt.Sprintf("uses banned library %q", hit.library),
+			})
+		}
+	}
+
+	for _, rule := range p.ForbiddenPatterns {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		for _, file := range sorted {
+			if !ruleMatchesFile(rule, file.Path) {
+				continue
+			}
+			for _, loc := range re.FindAllStringIndex(file.Content, 5) {
+				add(Violation{
+					Kind:     KindForbiddenPattern,
+					Rule:     rule.Name,
+					Path:     file.Path,
+					Line:     strings.Count(file.Content[:loc[0]], "\n") + 1,
+					Message:  ruleMessage(rule, "forbidden pattern found"),
+					Severity: rule.Severity,
+				})
+			}
+		}
+	}
+
+	for _, rule := range p.RequiredPatterns {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		matchedAny := false
+		var missing []string
+		for _, file := range sorted {
+			if !ruleMatchesFile(rule, file.Path) {
+				continue
+			}
+			matchedAny = true
+			if !re.MatchString(file.Content) {
+				missing = append(missing, file.Path)
+			}
+		}
+		// A rule scoped to files the build did not produce does not apply.
+		if !matchedAny {
+			continue
+		}
+		// Rules without a file scope only need one match across the build.
+		if rule.Files == "" {
+			if len(missing) == len(sorted) {
+				add(Violation{Kind: KindRequiredPattern, Rule: rule.Name, Message: ruleMessage(rule, "required pattern not found in any file"), Severity: rule.Severity})
+			}
+			continue
+		}
+		for _, filePath := range missing {
+			add(Violation{Kind: KindRequiredPattern, Rule: rule.Name, Path: filePath, Message: ruleMessage(rule, "required pattern missing"), Severity: rule.Severity})
+		}
+	}
+	return report
+}
+
+func ruleMessage(rule PatternRule, fallback string) string {
+	if msg := strings.TrimSpace(rule.Message); msg != "" {
+		return msg
+	}
+	return fallback
+}
+
+type libraryHit struct {
+	library string
+	line    int
+}
+
+var (
+	jsImportPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?:import|export)\s[^'"]*?from\s+['"]([^'"]+)['"]`),
+		regexp.MustCompile(`(?:^|[^\w.])import\s*\(?\s*['"]([^'"]+)['"]`),
+		regexp.MustCompile(`require\(\s*['"]([^'"]+)['"]\s*\)`),
+	}
+	pyImportPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^\s*from\s+([\w.]+)\s+import\b`),
+		regexp.MustCompile(`^\s*import\s+([\w.]+)`),
+	}
+	goImportPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^\s*(?:import\s+)?(?:[\w.]+\s+)?"([^"]+)"\s*$`),
+	}
+	requirementSuffix = regexp.MustCompile(`[<>=!~\[;].*$`)
+)
+
+func importPatternsFor(filePath string) []*regexp.Regexp {
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".vue", ".svelte":
+		return jsImportPatterns
+	case ".py":
+		return pyImportPatterns
+	case ".go":
+		return goImportPatterns
+	}
+	return nil
+}
+
+// bannedLibraryHits finds banned libraries in dependency manifests and import
+// statements.
+func bannedLibraryHits(file File, banned map[string]bool) []libraryHit {
+	if len(banned) == 0 {
+		return nil
+	}
+	base := strings.ToLower(path.Base(file.Path))
+	var hits []libraryHit
+	seen := make(map[string]bool)
+	record := func(name string, line int) {
+		for _, library := range libraryCandidates(strings.ToLower(strings.TrimSpace(name))) {
+			if banned[library] {
+				if !seen[library] {
+					seen[library] = true
+					hits = append(hits, libraryHit{library: library, line: line})
+				}
+				return
+			}
+		}
+	}
+
+	switch base {
+	case "package.json":
+		var manifest map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(file.Content), &manifest); err != nil {
+			return nil
+		}
+		for _, section := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+			var deps map[string]string
+			if raw, ok := manifest[section]; ok && json.Unmarshal(raw, &deps) == nil {
+				names := make([]string, 0, len(deps))
+				for name := range deps {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					record(name, lineOf(file.Content, `"`+name+`"`))
+				}
+			}
+		}
+		return hits
+	case "requirements.txt", "go.mod":
+		scanner := bufio.NewScanner(strings.NewReader(file.Content))
+		for line := 1; scanner.Scan(); line++ {
+			fields := strings.Fields(strings.SplitN(strings.SplitN(scanner.Text(), "#", 2)[0], "//", 2)[0])
+			if len(fields) == 0 {
+				continue
+			}
+			name := fields[0]
+			if base == "go.mod" {
+				if (name == "require" || name == "replace") && len(fields) > 1 {
+					name = fields[1]
+				}
+				if name == "module" || name == "go" || name == "toolchain" || name == "(" || name == ")" {
+					continue
+				}
+			} else {
+				name = requirementSuffix.ReplaceAllString(name, "")
+			}
+			record(name, line)
+		}
+		return hits
+	}
+
+	patterns := importPatternsFor(file.Path)
+	if len(patterns) == 0 {
+		return nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(file.Content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		for _, pattern := range patterns {
+			for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+				if match[1] != "" && !strings.HasPrefix(match[1], ".") {
+					record(match[1], line)
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// libraryCandidates lists the names an import may be banned under, most
+// specific first: "lodash/merge" -> [lodash/merge lodash],
+// "@scope/pkg/sub" -> [@scope/pkg/sub @scope/pkg], "requests.adapters" ->
+// [requests.adapters requests].
+func libraryCandidates(name string) []string {
+	if name == "" {
+		return nil
+	}
+	candidates := []string{name}
+	if strings.Contains(name, "/") {
+		parts := strings.Split(name, "/")
+		minParts := 1
+		if strings.HasPrefix(name, "@") {
+			minParts = 2
+		}
+		for n := len(parts) - 1; n >= minParts; n-- {
+			candidates = append(candidates, strings.Join(parts[:n], "/"))
+		}
+		return candidates
+	}
+	if idx := strings.Index(name, "."); idx > 0 {
+		candidates = append(candidates, name[:idx])
+	}
+	return candidates
+}
+
+func lineOf(content, needle string) int {
+	idx := strings.Index(content, needle)
+	if idx < 0 {
+		return 0
+	}
+	return strings.Count(content[:idx], "\n") + 1
+}
@@ -0,0 +1,126 @@
+package codestandards
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"apex-build/internal/enterprise"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCheckReportsBannedLibrariesAndPatterns(t *testing.T) {
+	policy := &Policy{
+		BannedLibraries: []string{" Moment ", "@acme/legacy", "requests", "moment"},
+		RequiredPatterns: []PatternRule{
+			{Name: "helmet", Pattern: `helmet\(`, Files: "server/*.ts", Message: "Express servers must use helmet"},
+			{Name: "license header", Pattern: `SPDX-License-Identifier`, Severity: SeverityWarning},
+		},
+		ForbiddenPatterns: []PatternRule{
+			{Name: "no eval", Pattern: `\beval\(`, Files: "*.ts"},
+		},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if len(policy.BannedLibraries) != 3 || policy.RequiredPatterns[0].Severity != SeverityError {
+		t.Fatalf("policy should be normalized, got %+v", policy)
+	}
+
+	files := []File{
+		{Path: "server/index.ts", Content: "import express from 'express'\nconst x = eval(input)\n"},
+		{Path: "src/date.ts", Content: "import dayjs from 'dayjs'\nimport { fmt } from 'moment/locale'\n"},
+		{Path: "package.json", Content: `{"dependencies":{"react":"^18","@acme/legacy":"1.0.0"}}`},
+		{Path: "api/app.py", Content: "from requests.adapters import HTTPAdapter\n"},
+	}
+	report := Check(policy, files)
+	again := Check(policy, []File{files[3], files[2], files[1], files[0]})
+	if len(report.Violations) != len(again.Violations) {
+		t.Fatalf("check must not depend on file order")
+	}
+	for idx := range report.Violations {
+		if report.Violations[idx] != again.Violations[idx] {
+			t.Fatalf("check must not depend on file order: %+v vs %+v", report.Violations[idx], again.Violations[idx])
+		}
+	}
+
+	found := map[string]Violation{}
+	for _, v := range report.Violations {
+		found[v.Kind+":"+v.Rule+":"+v.Path] = v
+	}
+	for _, key := range []string{
+		"banned_library:moment:src/date.ts",
+		"banned_library:@acme/legacy:package.json",
+		"banned_library:requests:api/app.py",
+		"forbidden_pattern:no eval:server/index.ts",
+		"required_pattern:helmet:server/index.ts",
+		"required_pattern:license header:",
+	} {
+		if _, ok := found[key]; !ok {
+			t.Fatalf("missing violation %s in %+v", key, report.Violations)
+		}
+	}
+	if v := found["forbidden_pattern:no eval:server/index.ts"]; v.Line != 2 {
+		t.Fatalf("forbidden pattern should report line 2, got %d", v.Line)
+	}
+	if report.Errors != 5 || report.Warnings != 1 || report.Passed() {
+		t.Fatalf("unexpected counts errors=%d warnings=%d", report.Errors, report.Warnings)
+	}
+
+	prompt := PromptContext(policy)
+	if !strings.Contains(prompt, "ORGANIZATION CODING STANDARDS") || !strings.Contains(prompt, "moment") || !strings.Contains(prompt, "Express servers must use helmet") {
+		t.Fatalf("unexpected prompt %q", prompt)
+	}
+
+	bad := &Policy{ForbiddenPatterns: []PatternRule{{Name: "broken", Pattern: "("}}}
+	if err := bad.Validate(); err == nil {
+		t.Fatal("invalid regexp should be rejected")
+	}
+}
+
+func TestServiceMergesPoliciesForActiveMemberships(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&enterprise.Organization{}, &enterprise.OrganizationMember{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate standards: %v", err)
+	}
+	acme := enterprise.Organization{Name: "Acme", Slug: "acme"}
+	globex := enterprise.Organization{Name: "Globex", Slug: "globex"}
+	db.Create(&acme)
+	db.Create(&globex)
+	db.Create(&enterprise.OrganizationMember{OrganizationID: acme.ID, UserID: 5, RoleID: 1, Status: "active"})
+	db.Create(&enterprise.OrganizationMember{OrganizationID: globex.ID, UserID: 5, RoleID: 1, Status: "suspended"})
+
+	svc := NewService(db)
+	ctx := context.Background()
+	if _, err := svc.Save(ctx, acme.ID, 1, Policy{StyleGuide: "Prefer named exports.", BannedLibraries: []string{"jquery"}}); err != nil {
+		t.Fatalf("save acme: %v", err)
+	}
+	if _, err := svc.Save(ctx, globex.ID, 1, Policy{BannedLibraries: []string{"lodash"}}); err != nil {
+		t.Fatalf("save globex: %v", err)
+	}
+	if _, err := svc.Save(ctx, acme.ID, 1, Policy{RequiredPatterns: []PatternRule{{Pattern: "x"}}}); err == nil {
+		t.Fatal("rules without a name should be rejected")
+	}
+
+	policy, err := svc.ForUser(ctx, 5)
+	if err != nil {
+		t.Fatalf("for user: %v", err)
+	}
+	if policy == nil || policy.StyleGuide != "Prefer named exports." || len(policy.BannedLibraries) != 1 || policy.BannedLibraries[0] != "jquery" {
+		t.Fatalf("only the active membership should apply, got %+v", policy)
+	}
+	if len(policy.Sources) != 1 || policy.Sources[0] != "Acme" {
+		t.Fatalf("policy should name its source organization, got %+v", policy.Sources)
+	}
+	if policy, err := svc.ForUser(ctx, 6); err != nil || policy != nil {
+		t.Fatalf("non-members should get no policy, got %+v (%v)", policy, err)
+	}
+}
@@ -127,6 +127,7 @@ type CollabHub struct {
 	otEngine        *OTEngine
 	accessResolver  AccessResolver
 	fileStore       FileStore
+	joinHook        JoinHook
 	register        chan *CollabClient
 	unregister      chan *CollabClient
 	broadcast       chan *broadcastMsg
@@ -273,6 +274,8 @@ func (h *CollabHub) addClientToRoom(client *CollabClient) {
 		Timestamp: time.Now(),
 	})
 
+	h.notifyJoin(client)
+
 	// Notify other clients
 	h.broadcastToRoomUnlocked(client.roomID, &CollabMessage{
 		Type:      MsgUserJoined,
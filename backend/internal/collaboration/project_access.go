@@ -30,14 +30,26 @@ type FileStore interface {
 	LoadFile(fileID uint) (projectID uint, content string, err error)
 }
 
+// OrgPermissions answers RBAC checks for organization-owned projects.
+type OrgPermissions interface {
+	HasPermission(orgID, userID uint, resource, action string) bool
+}
+
 type DatabaseAdapter struct {
-	db *gorm.DB
+	db       *gorm.DB
+	orgPerms OrgPermissions
 }
 
 func NewDatabaseAdapter(db *gorm.DB) *DatabaseAdapter {
 	return &DatabaseAdapter{db: db}
 }
 
+// SetOrgPermissions lets organization members join rooms for org-owned
+// projects: projects:update grants editor, projects:read grants viewer.
+func (a *DatabaseAdapter) SetOrgPermissions(perms OrgPermissions) {
+	a.orgPerms = perms
+}
+
 func ProjectRoomID(projectID uint) string {
 	return fmt.Sprintf("project_%d", projectID)
 }
@@ -56,13 +68,17 @@ func ProjectIDFromRoomID(roomID string) (uint, error) {
 	return uint(projectID), nil
 }
 
+// ResolveProjectAccess returns the user's permission on a project. An
+// organization project is owned by the organization: its owner_id only
+// records who created or last transferred it and grants nothing, so access
+// comes from the member's role alone.
 func (a *DatabaseAdapter) ResolveProjectAccess(userID, projectID uint) (*ProjectAccess, error) {
 	if a == nil || a.db == nil {
 		return nil, errors.New("collaboration access not configured")
 	}
 
 	var project models.Project
-	if err := a.db.Select("id", "owner_id", "is_public").First(&project, projectID).Error; err != nil {
+	if err := a.db.Select("id", "owner_id", "is_public", "organization_id").First(&project, projectID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrProjectNotFound
 		}
@@ -75,10 +91,19 @@ func (a *DatabaseAdapter) ResolveProjectAccess(userID, projectID uint) (*Project
 		Public:    project.IsPublic,
 	}
 
+	orgAllows := func(action string) bool {
+		return project.OrganizationID != nil && a.orgPerms != nil &&
+			a.orgPerms.HasPermission(*project.OrganizationID, userID, "projects", action)
+	}
+
 	switch {
-	case project.OwnerID == userID:
+	case project.OrganizationID == nil && project.OwnerID == userID:
 		access.Permission = PermissionOwner
-	case project.IsPublic:
+	case orgAllows("manage"):
+		access.Permission = PermissionAdmin
+	case orgAllows("update"):
+		access.Permission = PermissionEditor
+	case project.IsPublic, orgAllows("read"):
 		access.Permission = PermissionViewer
 	default:
 		return nil, ErrProjectAccessDenied
@@ -2,6 +2,7 @@ package collaboration
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"apex-build/pkg/models"
@@ -42,6 +43,43 @@ func TestDatabaseAdapterResolveProjectAccess(t *testing.T) {
 	require.True(t, errors.Is(err, ErrProjectAccessDenied))
 }
 
+type fakeOrgPermissions map[string]bool
+
+func (f fakeOrgPermissions) HasPermission(orgID, userID uint, resource, action string) bool {
+	return f[fmt.Sprintf("%d/%d/%s:%s", orgID, userID, resource, action)]
+}
+
+func TestDatabaseAdapterResolveOrgProjectAccess(t *testing.T) {
+	adapter := newTestDatabaseAdapter(t)
+	orgID := uint(5)
+	adapter.SetOrgPermissions(fakeOrgPermissions{
+		"5/10/projects:update": true,
+		"5/11/projects:read":   true,
+		"5/13/projects:manage": true,
+	})
+
+	require.NoError(t, adapter.db.Create(&models.Project{ID: 20, OwnerID: 7, Name: "org-app", Language: "go", OrganizationID: &orgID}).Error)
+
+	editor, err := adapter.ResolveProjectAccess(10, 20)
+	require.NoError(t, err)
+	require.Equal(t, PermissionEditor, editor.Permission)
+
+	admin, err := adapter.ResolveProjectAccess(13, 20)
+	require.NoError(t, err)
+	require.Equal(t, PermissionAdmin, admin.Permission)
+
+	viewer, err := adapter.ResolveProjectAccess(11, 20)
+	require.NoError(t, err)
+	require.Equal(t, PermissionViewer, viewer.Permission)
+
+	_, err = adapter.ResolveProjectAccess(12, 20)
+	require.True(t, errors.Is(err, ErrProjectAccessDenied))
+
+	// The creator recorded in owner_id has no rights without a role
+	_, err = adapter.ResolveProjectAccess(7, 20)
+	require.True(t, errors.Is(err, ErrProjectAccessDenied))
+}
+
 func TestDatabaseAdapterLoadFileAndRoomParsing(t *testing.T) {
 	adapter := newTestDatabaseAdapter(t)
 
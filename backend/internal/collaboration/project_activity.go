@@ -0,0 +1,36 @@
+package collaboration
+
+import "time"
+
+// JoinHook is called when a user joins a project's room, for example to
+// add the join to the project's activity feed. It runs on its own goroutine.
+type JoinHook func(projectID, userID uint, username string)
+
+// SetJoinHook reports room joins to hook
+func (h *CollabHub) SetJoinHook(hook JoinHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.joinHook = hook
+}
+
+// notifyJoin runs the join hook for a client (caller must hold h.mu)
+func (h *CollabHub) notifyJoin(client *CollabClient) {
+	if h.joinHook == nil || client.projectID == 0 {
+		return
+	}
+	go h.joinHook(client.projectID, client.userID, client.username)
+}
+
+// BroadcastProjectActivity pushes a project activity event to everyone in
+// the project's room as an "activity" message
+func (h *CollabHub) BroadcastProjectActivity(projectID, actorID uint, actorName string, at time.Time, event interface{}) {
+	roomID := ProjectRoomID(projectID)
+	h.BroadcastToRoom(roomID, &CollabMessage{
+		Type:      MsgActivity,
+		RoomID:    roomID,
+		UserID:    actorID,
+		Username:  actorName,
+		Data:      mustMarshal(event),
+		Timestamp: at,
+	}, 0)
+}
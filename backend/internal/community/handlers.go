@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"apex-build/internal/middleware"
+	"apex-build/internal/pagination"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -20,12 +21,13 @@ import (
 
 // CommunityHandler handles all community-related API endpoints
 type CommunityHandler struct {
-	DB *gorm.DB
+	DB    *gorm.DB
+	cards *shareCardCache
 }
 
 // NewCommunityHandler creates a new community handler
 func NewCommunityHandler(db *gorm.DB) *CommunityHandler {
-	return &CommunityHandler{DB: db}
+	return &CommunityHandler{DB: db, cards: newShareCardCache()}
 }
 
 // escapeLikePattern escapes special characters in LIKE patterns to prevent SQL injection
@@ -115,21 +117,18 @@ func (h *CommunityHandler) SearchProjects(c *gin.Context) {
 	query := c.Query("q")
 	category := c.Query("category")
 	language := c.Query("language")
-	sortBy := c.DefaultQuery("sort", "trending") // trending, recent, stars, forks
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 50 {
-		limit = 20
+	list, err := pagination.Parse(c, discoverListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_PAGINATION",
+		})
+		return
 	}
-	offset := (page - 1) * limit
 
 	// Build query
 	dbQuery := h.DB.Model(&models.Project{}).
-		Preload("Owner").
 		Where("is_public = ?", true)
 
 	// Text search
@@ -157,57 +156,23 @@ func (h *CommunityHandler) SearchProjects(c *gin.Context) {
 		dbQuery = dbQuery.Where("language = ?", language)
 	}
 
-	// Get total count
-	var total int64
-	dbQuery.Count(&total)
-
-	// Apply sorting
-	switch sortBy {
-	case "recent":
-		dbQuery = dbQuery.Order("created_at DESC")
-	case "stars":
-		dbQuery = dbQuery.Joins("LEFT JOIN project_stats ON project_stats.project_id = projects.id").
-			Order("COALESCE(project_stats.star_count, 0) DESC")
-	case "forks":
-		dbQuery = dbQuery.Joins("LEFT JOIN project_stats ON project_stats.project_id = projects.id").
-			Order("COALESCE(project_stats.fork_count, 0) DESC")
-	default: // trending
-		dbQuery = dbQuery.Joins("LEFT JOIN project_stats ON project_stats.project_id = projects.id").
-			Order("COALESCE(project_stats.trend_score, 0) DESC")
-	}
-
-	// Fetch projects
-	var projects []models.Project
-	dbQuery.Offset(offset).Limit(limit).Find(&projects)
-
-	// Enrich with stats
-	projectsWithStats := h.enrichProjects(projects, userID)
-
-	c.JSON(http.StatusOK, gin.H{
-		"projects": projectsWithStats,
-		"pagination": gin.H{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+	projectsWithStats, pageInfo, total := h.listProjects(dbQuery, list, userID)
+	h.respondProjectList(c, projectsWithStats, pageInfo, total, list, nil)
 }
 
 // GetProjectsByCategory returns projects in a category
 func (h *CommunityHandler) GetProjectsByCategory(c *gin.Context) {
 	userID, _ := middleware.GetUserID(c)
 	slug := c.Param("slug")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 50 {
-		limit = 20
+	list, err := pagination.Parse(c, discoverListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_PAGINATION",
+		})
+		return
 	}
-	offset := (page - 1) * limit
 
 	// Find category
 	var category ProjectCategory
@@ -225,31 +190,10 @@ func (h *CommunityHandler) GetProjectsByCategory(c *gin.Context) {
 		Select("project_id").
 		Where("category_id = ?", category.ID)
 
-	var total int64
-	h.DB.Model(&models.Project{}).
-		Where("id IN (?) AND is_public = ?", subQuery, true).
-		Count(&total)
-
-	var projects []models.Project
-	h.DB.Preload("Owner").
-		Where("id IN (?) AND is_public = ?", subQuery, true).
-		Joins("LEFT JOIN project_stats ON project_stats.project_id = projects.id").
-		Order("COALESCE(project_stats.trend_score, 0) DESC").
-		Offset(offset).Limit(limit).
-		Find(&projects)
-
-	projectsWithStats := h.enrichProjects(projects, userID)
-
-	c.JSON(http.StatusOK, gin.H{
-		"category": category,
-		"projects": projectsWithStats,
-		"pagination": gin.H{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+	dbQuery := h.DB.Model(&models.Project{}).
+		Where("id IN (?) AND is_public = ?", subQuery, true)
+	projectsWithStats, pageInfo, total := h.listProjects(dbQuery, list, userID)
+	h.respondProjectList(c, projectsWithStats, pageInfo, total, list, gin.H{"category": category})
 }
 
 // ========== PROJECT PAGE ENDPOINTS ==========
@@ -340,7 +284,7 @@ func (h *CommunityHandler) GetPublicProject(c *gin.Context) {
 		Limit(20).
 		Find(&comments)
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"project":    project,
 		"stats":      stats,
 		"is_starred": isStarred,
@@ -349,7 +293,11 @@ func (h *CommunityHandler) GetPublicProject(c *gin.Context) {
 		"categories": categories,
 		"readme":     readmeContent,
 		"comments":   comments,
-	})
+	}
+	if project.IsPublic {
+		response["share"] = projectShareLinks(c, owner.Username, project.Name)
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // ========== STAR ENDPOINTS ==========
@@ -833,16 +781,15 @@ func (h *CommunityHandler) GetUserProfile(c *gin.Context) {
 func (h *CommunityHandler) GetUserProjects(c *gin.Context) {
 	username := c.Param("username")
 	currentUserID, authenticated := middleware.GetUserID(c)
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 50 {
-		limit = 20
+	list, err := pagination.Parse(c, profileListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_PAGINATION",
+		})
+		return
 	}
-	offset := (page - 1) * limit
 
 	var user models.User
 	if err := h.DB.Where("username = ?", username).First(&user).Error; err != nil {
@@ -860,42 +807,23 @@ func (h *CommunityHandler) GetUserProjects(c *gin.Context) {
 		query = query.Where("is_public = ?", true)
 	}
 
-	var total int64
-	query.Count(&total)
-
-	var projects []models.Project
-	query.Preload("Owner").
-		Order("updated_at DESC").
-		Offset(offset).Limit(limit).
-		Find(&projects)
-
-	projectsWithStats := h.enrichProjects(projects, currentUserID)
-
-	c.JSON(http.StatusOK, gin.H{
-		"projects": projectsWithStats,
-		"pagination": gin.H{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+	projectsWithStats, pageInfo, total := h.listProjects(query, list, currentUserID)
+	h.respondProjectList(c, projectsWithStats, pageInfo, total, list, nil)
 }
 
 // GetUserStarredProjects returns projects a user has starred
 func (h *CommunityHandler) GetUserStarredProjects(c *gin.Context) {
 	username := c.Param("username")
 	currentUserID, _ := middleware.GetUserID(c)
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 50 {
-		limit = 20
+	list, err := pagination.Parse(c, profileListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_PAGINATION",
+		})
+		return
 	}
-	offset := (page - 1) * limit
 
 	var user models.User
 	if err := h.DB.Where("username = ?", username).First(&user).Error; err != nil {
@@ -910,29 +838,10 @@ func (h *CommunityHandler) GetUserStarredProjects(c *gin.Context) {
 	// Get starred project IDs
 	subQuery := h.DB.Model(&ProjectStar{}).Select("project_id").Where("user_id = ?", user.ID)
 
-	var total int64
-	h.DB.Model(&models.Project{}).
-		Where("id IN (?) AND is_public = ?", subQuery, true).
-		Count(&total)
-
-	var projects []models.Project
-	h.DB.Preload("Owner").
-		Where("id IN (?) AND is_public = ?", subQuery, true).
-		Order("updated_at DESC").
-		Offset(offset).Limit(limit).
-		Find(&projects)
-
-	projectsWithStats := h.enrichProjects(projects, currentUserID)
-
-	c.JSON(http.StatusOK, gin.H{
-		"projects": projectsWithStats,
-		"pagination": gin.H{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+	dbQuery := h.DB.Model(&models.Project{}).
+		Where("id IN (?) AND is_public = ?", subQuery, true)
+	projectsWithStats, pageInfo, total := h.listProjects(dbQuery, list, currentUserID)
+	h.respondProjectList(c, projectsWithStats, pageInfo, total, list, nil)
 }
 
 // ========== FOLLOW ENDPOINTS ==========
@@ -1304,6 +1213,94 @@ func (h *CommunityHandler) GetProjectCategories(c *gin.Context) {
 
 // ========== HELPER FUNCTIONS ==========
 
+// projectSorts are the sort options for community project listings. Stat
+// sorts read the joined project_stats row, so unranked projects count as 0.
+var projectSorts = map[string]pagination.Sort{
+	"trending": {Key: "trending", Column: "COALESCE(project_stats.trend_score, 0)", Desc: true},
+	"recent":   {Key: "recent", Column: "projects.created_at", Desc: true},
+	"updated":  {Key: "updated", Column: "projects.updated_at", Desc: true},
+	"stars":    {Key: "stars", Column: "COALESCE(project_stats.star_count, 0)", Desc: true},
+	"forks":    {Key: "forks", Column: "COALESCE(project_stats.fork_count, 0)", Desc: true},
+}
+
+// projectListSpec builds a community listing spec with the given default sort.
+func projectListSpec(defaultSort string) pagination.Spec {
+	spec := pagination.Spec{
+		DefaultLimit: 20,
+		MaxLimit:     50,
+		IDColumn:     "projects.id",
+		Sorts:        []pagination.Sort{projectSorts[defaultSort]},
+	}
+	for _, key := range []string{"trending", "recent", "updated", "stars", "forks"} {
+		if key != defaultSort {
+			spec.Sorts = append(spec.Sorts, projectSorts[key])
+		}
+	}
+	return spec
+}
+
+var (
+	discoverListSpec = projectListSpec("trending")
+	profileListSpec  = projectListSpec("updated")
+)
+
+// listProjects counts and pages a filtered project query, returning the page
+// enriched with stats.
+func (h *CommunityHandler) listProjects(query *gorm.DB, list *pagination.Request, userID uint) ([]ProjectWithStats, pagination.Info, int64) {
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var projects []models.Project
+	list.Apply(query.Preload("Owner").
+		Joins("LEFT JOIN project_stats ON project_stats.project_id = projects.id")).
+		Find(&projects)
+
+	projectsWithStats, pageInfo := pagination.Page(list, h.enrichProjects(projects, userID), func(p ProjectWithStats) (any, any) {
+		switch list.Sort.Key {
+		case "trending":
+			return p.Stats.TrendScore, p.ID
+		case "stars":
+			return p.Stats.StarCount, p.ID
+		case "forks":
+			return p.Stats.ForkCount, p.ID
+		case "recent":
+			return p.CreatedAt, p.ID
+		}
+		return p.UpdatedAt, p.ID
+	})
+	return projectsWithStats, pageInfo, total
+}
+
+// respondProjectList writes a community project page with the legacy
+// pagination object alongside page_info.
+func (h *CommunityHandler) respondProjectList(c *gin.Context, projects []ProjectWithStats, pageInfo pagination.Info, total int64, list *pagination.Request, extra gin.H) {
+	selected, err := pagination.SelectFields(projects, list.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to select fields",
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	page := list.Offset/list.Limit + 1
+	response := gin.H{
+		"projects": selected,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       list.Limit,
+			"total":       total,
+			"total_pages": (total + int64(list.Limit) - 1) / int64(list.Limit),
+		},
+		"page_info": pageInfo,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *CommunityHandler) enrichProjects(projects []models.Project, userID uint) []ProjectWithStats {
 	result := make([]ProjectWithStats, len(projects))
 
@@ -1351,7 +1348,7 @@ func (h *CommunityHandler) enrichProject(p models.Project, userID uint) ProjectW
 	return pws
 }
 
-func (h *CommunityHandler) recordView(projectID uint, userID uint, ip string) {
+func (h *CommunityHandler) recordView(projectID uint, userID uint, ip string) bool {
 	// Hash IP for privacy
 	hash := sha256.Sum256([]byte(ip))
 	ipHash := hex.EncodeToString(hash[:8])
@@ -1368,7 +1365,7 @@ func (h *CommunityHandler) recordView(projectID uint, userID uint, ip string) {
 	}
 
 	if query.First(&existing).Error == nil {
-		return // Already viewed recently
+		return false // Already viewed recently
 	}
 
 	// Record view
@@ -1386,6 +1383,7 @@ func (h *CommunityHandler) recordView(projectID uint, userID uint, ip string) {
 	h.DB.Model(&ProjectStats{}).
 		Where("project_id = ?", projectID).
 		UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+	return true
 }
 
 func (h *CommunityHandler) updateProjectStats(projectID uint) {
@@ -1484,6 +1482,7 @@ func (h *CommunityHandler) RegisterRoutes(router *gin.RouterGroup) {
 	router.GET("/explore/search", h.SearchProjects)
 	router.GET("/explore/categories", h.GetCategories)
 	router.GET("/explore/category/:slug", h.GetProjectsByCategory)
+	router.GET("/explore/category/:slug/og.png", h.GetCategoryShareImage)
 
 	// User profile routes (mostly public)
 	router.GET("/users/:username", h.GetUserProfile)
@@ -1495,6 +1494,17 @@ func (h *CommunityHandler) RegisterRoutes(router *gin.RouterGroup) {
 	// Project page route (public)
 	router.GET("/project/:username/:project", h.GetPublicProject)
 	router.GET("/projects/:id/comments", h.GetComments)
+
+	// Read-only code viewer for public projects (rate limited per IP)
+	viewer := router.Group("/project/:username/:project", h.publicViewerRateLimit())
+	viewer.GET("/tree", h.GetPublicFileTree)
+	viewer.GET("/file", h.GetPublicFile)
+	viewer.GET("/embed", h.GetPublicFileEmbed)
+
+	// Badges and share cards for READMEs and social links
+	viewer.GET("/badges/:badge", h.GetProjectBadge)
+	viewer.GET("/og.png", h.GetProjectShareImage)
+	viewer.GET("/share", h.GetProjectSharePage)
 }
 
 // RegisterProtectedRoutes registers routes that require authentication
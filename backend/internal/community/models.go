@@ -109,6 +109,7 @@ type ProjectStats struct {
 	StarCount  int       `json:"star_count" gorm:"default:0"`
 	ForkCount  int       `json:"fork_count" gorm:"default:0"`
 	ViewCount  int       `json:"view_count" gorm:"default:0"`
+	EmbedViewCount int   `json:"embed_view_count" gorm:"default:0"` // Views through embedded snippets
 	CommentCount int     `json:"comment_count" gorm:"default:0"`
 	TrendScore float64   `json:"trend_score" gorm:"default:0"` // Calculated trending score
 	UpdatedAt  time.Time `json:"updated_at"`
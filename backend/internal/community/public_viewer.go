@@ -0,0 +1,522 @@
+// APEX.BUILD Community Public Code Viewer
+// Read-only file browsing and embeddable snippets for public projects
+
+package community
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/middleware"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+const (
+	// Unauthenticated viewer requests allowed per IP
+	publicViewerRequestsPerMinute = 120
+	publicViewerBurst             = 30
+
+	// maxPublicFileBytes caps the content returned for a single file
+	maxPublicFileBytes = 512 << 10
+
+	// maxEmbedLines caps the lines rendered into an embed
+	maxEmbedLines = 500
+)
+
+// PublicFileNode is an entry in a public project's file tree
+type PublicFileNode struct {
+	Path     string            `json:"path"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"` // file, directory
+	Size     int64             `json:"size,omitempty"`
+	Language string            `json:"language,omitempty"`
+	IsBinary bool              `json:"is_binary,omitempty"`
+	Children []*PublicFileNode `json:"children,omitempty"`
+}
+
+// PublicFile is a read-only file with the metadata a viewer needs to
+// highlight it
+type PublicFile struct {
+	Path      string    `json:"path"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Language  string    `json:"language"`
+	MimeType  string    `json:"mime_type,omitempty"`
+	LineCount int       `json:"line_count"`
+	IsBinary  bool      `json:"is_binary"`
+	Truncated bool      `json:"truncated"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// syntaxLanguages maps file extensions to highlighter language ids
+var syntaxLanguages = map[string]string{
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".mts": "typescript", ".tsx": "tsx",
+	".py": "python", ".go": "go", ".rs": "rust", ".rb": "ruby", ".php": "php",
+	".java": "java", ".kt": "kotlin", ".swift": "swift", ".cs": "csharp",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".cxx": "cpp", ".hpp": "cpp",
+	".ex": "elixir", ".exs": "elixir", ".lua": "lua", ".dart": "dart", ".scala": "scala",
+	".html": "html", ".htm": "html", ".vue": "vue", ".svelte": "svelte",
+	".css": "css", ".scss": "scss", ".sass": "sass", ".less": "less",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".xml": "xml",
+	".md": "markdown", ".mdx": "markdown", ".sql": "sql", ".graphql": "graphql", ".gql": "graphql",
+	".sh": "bash", ".bash": "bash", ".zsh": "bash", ".ps1": "powershell",
+	".prisma": "prisma", ".proto": "protobuf", ".tf": "hcl", ".ini": "ini", ".env": "dotenv",
+}
+
+// syntaxFilenames covers files identified by name rather than extension
+var syntaxFilenames = map[string]string{
+	"dockerfile": "docker",
+	"makefile":   "makefile",
+	"gemfile":    "ruby",
+	"procfile":   "yaml",
+	".gitignore": "ignore",
+}
+
+// SyntaxLanguage returns the highlighter language for a file path, or
+// "plaintext" when it is not recognised
+func SyntaxLanguage(filePath string) string {
+	name := strings.ToLower(path.Base(filePath))
+	if lang, ok := syntaxFilenames[name]; ok {
+		return lang
+	}
+	if strings.HasPrefix(name, "dockerfile.") {
+		return "docker"
+	}
+	if lang, ok := syntaxLanguages[path.Ext(name)]; ok {
+		return lang
+	}
+	return "plaintext"
+}
+
+// publicViewerHidden reports whether a file must never be served publicly,
+// even from a public project
+func publicViewerHidden(filePath string) bool {
+	for _, segment := range strings.Split(filePath, "/") {
+		if segment == ".git" || segment == "node_modules" {
+			return true
+		}
+	}
+	name := strings.ToLower(path.Base(filePath))
+	if name == ".env" || (strings.HasPrefix(name, ".env.") && name != ".env.example") {
+		return true
+	}
+	return name == ".npmrc" || name == ".pypirc" || name == ".netrc" ||
+		strings.HasSuffix(name, ".pem") || strings.HasSuffix(name, ".key")
+}
+
+// normalizePublicPath cleans a requested file path into the repo-relative
+// form files are stored under
+func normalizePublicPath(filePath string) (string, bool) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(filePath)), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// buildPublicFileTree nests flat file records into a sorted tree, adding
+// the directories their paths imply
+func buildPublicFileTree(files []models.File) []*PublicFileNode {
+	root := &PublicFileNode{Type: "directory"}
+	dirs := map[string]*PublicFileNode{"": root}
+
+	var ensureDir func(dirPath string) *PublicFileNode
+	ensureDir = func(dirPath string) *PublicFileNode {
+		if node, ok := dirs[dirPath]; ok {
+			return node
+		}
+		parentPath := path.Dir(dirPath)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		node := &PublicFileNode{Path: dirPath, Name: path.Base(dirPath), Type: "directory"}
+		parent := ensureDir(parentPath)
+		parent.Children = append(parent.Children, node)
+		dirs[dirPath] = node
+		return node
+	}
+
+	for _, file := range files {
+		filePath, ok := normalizePublicPath(file.Path)
+		if !ok || publicViewerHidden(filePath) {
+			continue
+		}
+		if file.Type == "directory" {
+			ensureDir(filePath)
+			continue
+		}
+		parentPath := path.Dir(filePath)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		node := &PublicFileNode{
+			Path:     filePath,
+			Name:     path.Base(filePath),
+			Type:     "file",
+			Size:     file.Size,
+			IsBinary: file.IsBinary,
+		}
+		if !file.IsBinary {
+			node.Language = SyntaxLanguage(filePath)
+		}
+		parent := ensureDir(parentPath)
+		parent.Children = append(parent.Children, node)
+	}
+
+	var sortNodes func(nodes []*PublicFileNode)
+	sortNodes = func(nodes []*PublicFileNode) {
+		sort.Slice(nodes, func(i, j int) bool {
+			if nodes[i].Type != nodes[j].Type {
+				return nodes[i].Type == "directory"
+			}
+			return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name)
+		})
+		for _, node := range nodes {
+			sortNodes(node.Children)
+		}
+	}
+	sortNodes(root.Children)
+	return root.Children
+}
+
+// toPublicFile converts a stored file for read-only display, truncating
+// very large content at a line boundary
+func toPublicFile(file *models.File, filePath string) PublicFile {
+	public := PublicFile{
+		Path:      filePath,
+		Name:      path.Base(filePath),
+		Size:      file.Size,
+		Language:  SyntaxLanguage(filePath),
+		MimeType:  file.MimeType,
+		IsBinary:  file.IsBinary,
+		UpdatedAt: file.UpdatedAt,
+	}
+	if file.IsBinary {
+		public.Language = ""
+		return public
+	}
+
+	content := file.Content
+	if len(content) > maxPublicFileBytes {
+		content = content[:maxPublicFileBytes]
+		if cut := strings.LastIndexByte(content, '\n'); cut > 0 {
+			content = content[:cut+1]
+		}
+		public.Truncated = true
+	}
+	public.Content = content
+	public.LineCount = countLines(content)
+	return public
+}
+
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return lines
+}
+
+// parseLineRange parses an embed's lines parameter ("12" or "12-40")
+// against a file's line count
+func parseLineRange(raw string, total int) (int, int) {
+	start, end := 1, total
+	if raw = strings.TrimSpace(raw); raw != "" {
+		from, to, isRange := strings.Cut(raw, "-")
+		if n, err := strconv.Atoi(strings.TrimSpace(from)); err == nil && n > 0 {
+			start = n
+			if !isRange {
+				end = n
+			}
+		}
+		if isRange {
+			if n, err := strconv.Atoi(strings.TrimSpace(to)); err == nil && n > 0 {
+				end = n
+			}
+		}
+	}
+	if end > total {
+		end = total
+	}
+	if start > end {
+		start = end
+	}
+	if start < 1 {
+		start = 1
+	}
+	if end-start+1 > maxEmbedLines {
+		end = start + maxEmbedLines - 1
+	}
+	return start, end
+}
+
+// publicAPIBaseURL is the API origin embeds load from. PUBLIC_API_URL
+// overrides the request host when the API sits behind a proxy.
+func publicAPIBaseURL(c *gin.Context) string {
+	if base := strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwardedProto := strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Proto"), ",")[0]); forwardedProto != "" {
+		scheme = forwardedProto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// publicProjectURL is the shareable cover page for a public project
+func publicProjectURL(username, projectName string) string {
+	appURL := strings.TrimRight(os.Getenv("APP_URL"), "/")
+	if appURL == "" {
+		appURL = strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+	}
+	if appURL == "" {
+		appURL = "https://apex-build.dev"
+	}
+	return fmt.Sprintf("%s/@%s/%s", appURL, url.PathEscape(username), url.PathEscape(projectName))
+}
+
+// embedSnippet returns the embed URL and iframe markup for a single file
+func embedSnippet(c *gin.Context, username, projectName string, file PublicFile) gin.H {
+	embedURL := fmt.Sprintf("%s/api/v1/project/%s/%s/embed?path=%s",
+		publicAPIBaseURL(c), url.PathEscape(username), url.PathEscape(projectName), url.QueryEscape(file.Path))
+
+	lines := file.LineCount
+	if lines > 30 {
+		lines = 30
+	}
+	height := 48 + lines*20
+	iframe := fmt.Sprintf(`<iframe src="%s" title="%s" width="100%%" height="%d" style="border:0;border-radius:8px" loading="lazy" sandbox="allow-popups allow-popups-to-escape-sandbox"></iframe>`,
+		template.HTMLEscapeString(embedURL),
+		template.HTMLEscapeString(file.Path+" · "+username+"/"+projectName),
+		height)
+
+	return gin.H{
+		"url":  embedURL,
+		"html": iframe,
+	}
+}
+
+// publicViewerRateLimit limits unauthenticated viewer traffic per IP
+func (h *CommunityHandler) publicViewerRateLimit() gin.HandlerFunc {
+	limiter := middleware.NewScopedIPRateLimiter(rate.Limit(publicViewerRequestsPerMinute)/60, publicViewerBurst, "community_viewer")
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			c.Header("Retry-After", "60")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, StandardResponse{
+				Success: false,
+				Error:   "Too many requests. Please try again later.",
+				Code:    "RATE_LIMITED",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// findPublicProject resolves a public project from its owner's username and
+// project name. Private projects are reported as not found.
+func (h *CommunityHandler) findPublicProject(c *gin.Context) (*models.Project, bool) {
+	var project models.Project
+	err := h.DB.Joins("JOIN users ON users.id = projects.owner_id").
+		Where("users.username = ? AND projects.name = ? AND projects.is_public = ?", c.Param("username"), c.Param("project"), true).
+		First(&project).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   "Project not found",
+			Code:    "PROJECT_NOT_FOUND",
+		})
+		return nil, false
+	}
+	return &project, true
+}
+
+// findPublicFile loads the file named by the path query parameter
+func (h *CommunityHandler) findPublicFile(c *gin.Context, projectID uint) (*models.File, string, bool) {
+	filePath, ok := normalizePublicPath(c.Query("path"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "path is required",
+			Code:    "INVALID_PATH",
+		})
+		return nil, "", false
+	}
+
+	var file models.File
+	err := h.DB.Where("project_id = ? AND type <> ? AND (path = ? OR path = ?)", projectID, "directory", filePath, "/"+filePath).
+		First(&file).Error
+	if err != nil || publicViewerHidden(filePath) {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   "File not found",
+			Code:    "FILE_NOT_FOUND",
+		})
+		return nil, "", false
+	}
+	return &file, filePath, true
+}
+
+// countPublicView records a deduplicated view of a public project, and for
+// embeds also counts the embed load
+func (h *CommunityHandler) countPublicView(c *gin.Context, projectID uint, embed bool) {
+	userID, _ := middleware.GetUserID(c)
+	var stats ProjectStats
+	h.DB.FirstOrCreate(&stats, ProjectStats{ProjectID: projectID})
+	if h.recordView(projectID, userID, c.ClientIP()) && embed {
+		h.DB.Model(&ProjectStats{}).
+			Where("project_id = ?", projectID).
+			UpdateColumn("embed_view_count", gorm.Expr("embed_view_count + 1"))
+	}
+}
+
+// GetPublicFileTree returns a public project's file tree without contents
+// GET /api/v1/project/:username/:project/tree
+func (h *CommunityHandler) GetPublicFileTree(c *gin.Context) {
+	project, ok := h.findPublicProject(c)
+	if !ok {
+		return
+	}
+
+	var files []models.File
+	h.DB.Select("id", "path", "name", "type", "size", "is_binary").
+		Where("project_id = ?", project.ID).
+		Order("path ASC").
+		Find(&files)
+
+	h.countPublicView(c, project.ID, false)
+
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"project_id": project.ID,
+		"language":   project.Language,
+		"share_url":  publicProjectURL(c.Param("username"), project.Name),
+		"tree":       buildPublicFileTree(files),
+	})
+}
+
+// GetPublicFile returns one file of a public project with syntax metadata
+// and its embed snippet
+// GET /api/v1/project/:username/:project/file?path=
+func (h *CommunityHandler) GetPublicFile(c *gin.Context) {
+	project, ok := h.findPublicProject(c)
+	if !ok {
+		return
+	}
+	file, filePath, ok := h.findPublicFile(c, project.ID)
+	if !ok {
+		return
+	}
+
+	public := toPublicFile(file, filePath)
+	response := gin.H{
+		"success":    true,
+		"project_id": project.ID,
+		"file":       public,
+	}
+	if !public.IsBinary {
+		response["embed"] = embedSnippet(c, c.Param("username"), project.Name, public)
+	}
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, response)
+}
+
+// embedLine is one rendered line of an embed
+type embedLine struct {
+	Number int
+	Text   string
+}
+
+var embedTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Path}} · {{.Owner}}/{{.Project}}</title>
+<style>
+body{margin:0;font:13px/20px ui-monospace,SFMono-Regular,Menlo,Consolas,monospace;background:{{if .Light}}#ffffff{{else}}#0d1117{{end}};color:{{if .Light}}#1f2328{{else}}#e6edf3{{end}}}
+header{display:flex;justify-content:space-between;gap:12px;padding:6px 12px;font-family:system-ui,sans-serif;font-size:12px;border-bottom:1px solid {{if .Light}}#d0d7de{{else}}#30363d{{end}}}
+header a{color:inherit;text-decoration:none}
+.lang{opacity:.6}
+pre{margin:0;padding:6px 0;overflow:auto}
+table{border-collapse:collapse}
+td.n{padding:0 12px;text-align:right;user-select:none;opacity:.45}
+td.l{padding-right:12px;white-space:pre}
+</style>
+</head>
+<body>
+<header><a href="{{.ProjectURL}}" target="_blank" rel="noopener">{{.Owner}}/{{.Project}} · {{.Path}}</a><span class="lang">{{.Language}}</span></header>
+<pre class="language-{{.Language}}" data-language="{{.Language}}"><table>{{range .Lines}}<tr><td class="n">{{.Number}}</td><td class="l">{{.Text}}</td></tr>{{end}}</table></pre>
+</body>
+</html>
+`))
+
+// GetPublicFileEmbed renders one file of a public project as a
+// self-contained HTML page for iframes
+// GET /api/v1/project/:username/:project/embed?path=&lines=&theme=
+func (h *CommunityHandler) GetPublicFileEmbed(c *gin.Context) {
+	project, ok := h.findPublicProject(c)
+	if !ok {
+		return
+	}
+	file, filePath, ok := h.findPublicFile(c, project.ID)
+	if !ok {
+		return
+	}
+	public := toPublicFile(file, filePath)
+	if public.IsBinary {
+		c.JSON(http.StatusUnsupportedMediaType, StandardResponse{
+			Success: false,
+			Error:   "Binary files cannot be embedded",
+			Code:    "BINARY_FILE",
+		})
+		return
+	}
+
+	source := strings.Split(strings.TrimSuffix(public.Content, "\n"), "\n")
+	start, end := parseLineRange(c.Query("lines"), len(source))
+	lines := make([]embedLine, 0, end-start+1)
+	for i := start; i <= end && i <= len(source); i++ {
+		lines = append(lines, embedLine{Number: i, Text: strings.TrimSuffix(source[i-1], "\r")})
+	}
+
+	h.countPublicView(c, project.ID, true)
+
+	// Embeds are meant to be framed anywhere, but run no script
+	c.Writer.Header().Del("X-Frame-Options")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors *; base-uri 'none'; form-action 'none'")
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = embedTemplate.Execute(c.Writer, gin.H{
+		"Owner":      c.Param("username"),
+		"Project":    project.Name,
+		"Path":       filePath,
+		"Language":   public.Language,
+		"Light":      c.Query("theme") == "light",
+		"ProjectURL": publicProjectURL(c.Param("username"), project.Name),
+		"Lines":      lines,
+	})
+}
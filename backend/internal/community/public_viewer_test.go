@@ -0,0 +1,142 @@
+package community
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBuildPublicFileTree(t *testing.T) {
+	tree := buildPublicFileTree([]models.File{
+		{Path: "/src/main.go", Type: "file", Size: 10},
+		{Path: "README.md", Type: "file"},
+		{Path: "src/lib/util.ts", Type: "file"},
+		{Path: "assets", Type: "directory"},
+		{Path: "assets/logo.png", Type: "file", IsBinary: true},
+		{Path: ".env", Type: "file"},
+		{Path: ".env.example", Type: "file"},
+		{Path: "node_modules/x/index.js", Type: "file"},
+	})
+
+	var names []string
+	for _, node := range tree {
+		names = append(names, node.Name)
+	}
+	if got := strings.Join(names, ","); got != "assets,src,.env.example,README.md" {
+		t.Fatalf("top level = %s", got)
+	}
+	src := tree[1]
+	if len(src.Children) != 2 || src.Children[0].Path != "src/lib" || src.Children[1].Language != "go" {
+		t.Fatalf("src children = %+v", src.Children)
+	}
+	if logo := tree[0].Children[0]; !logo.IsBinary || logo.Language != "" {
+		t.Fatalf("logo = %+v", logo)
+	}
+}
+
+func TestSyntaxLanguageAndLineRange(t *testing.T) {
+	for filePath, want := range map[string]string{
+		"src/App.tsx":     "tsx",
+		"Dockerfile":      "docker",
+		"Dockerfile.prod": "docker",
+		"docs/guide.MD":   "markdown",
+		"LICENSE":         "plaintext",
+	} {
+		if got := SyntaxLanguage(filePath); got != want {
+			t.Errorf("SyntaxLanguage(%q) = %q, want %q", filePath, got, want)
+		}
+	}
+
+	cases := []struct {
+		raw                   string
+		total, start, wantEnd int
+	}{
+		{"", 40, 1, 40},
+		{"12", 40, 12, 12},
+		{"10-20", 40, 10, 20},
+		{"30-99", 40, 30, 40},
+		{"50-60", 40, 40, 40},
+		{"junk", 1000, 1, maxEmbedLines},
+	}
+	for _, tc := range cases {
+		if start, end := parseLineRange(tc.raw, tc.total); start != tc.start || end != tc.wantEnd {
+			t.Errorf("parseLineRange(%q, %d) = %d-%d, want %d-%d", tc.raw, tc.total, start, end, tc.start, tc.wantEnd)
+		}
+	}
+}
+
+func TestPublicViewerEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate community: %v", err)
+	}
+
+	owner := &models.User{Username: "ada", Email: "ada@example.com", PasswordHash: "x"}
+	if err := db.Create(owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	public := &models.Project{Name: "demo", Language: "go", OwnerID: owner.ID, IsPublic: true}
+	private := &models.Project{Name: "secret", Language: "go", OwnerID: owner.ID}
+	for _, project := range []*models.Project{public, private} {
+		if err := db.Create(project).Error; err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+		if err := db.Create(&models.File{ProjectID: project.ID, Path: "main.go", Name: "main.go", Type: "file", Content: "package main\n\nfunc main() { println(\"<b>\") }\n"}).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+	if err := db.Create(&models.File{ProjectID: public.ID, Path: ".env", Name: ".env", Type: "file", Content: "TOKEN=1"}).Error; err != nil {
+		t.Fatalf("create env file: %v", err)
+	}
+
+	router := gin.New()
+	NewCommunityHandler(db).RegisterRoutes(router.Group("/api/v1"))
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := get("/api/v1/project/ada/demo/tree"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), ".env") {
+		t.Fatalf("tree = %d %s", w.Code, w.Body.String())
+	}
+	w := get("/api/v1/project/ada/demo/file?path=/main.go")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"language":"go"`) || !strings.Contains(w.Body.String(), `"line_count":3`) || !strings.Contains(w.Body.String(), `iframe src=`) {
+		t.Fatalf("file = %d %s", w.Code, w.Body.String())
+	}
+	for _, target := range []string{
+		"/api/v1/project/ada/secret/tree",
+		"/api/v1/project/ada/demo/file?path=.env",
+		"/api/v1/project/ada/demo/file?path=../secret/main.go",
+	} {
+		if w := get(target); w.Code != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", target, w.Code)
+		}
+	}
+
+	w = get("/api/v1/project/ada/demo/embed?path=main.go&lines=3")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "&lt;b&gt;") || strings.Contains(body, "package main") || !strings.Contains(w.Header().Get("Content-Security-Policy"), "frame-ancestors *") {
+		t.Fatalf("embed = %d %s", w.Code, body)
+	}
+
+	var stats ProjectStats
+	db.First(&stats, "project_id = ?", public.ID)
+	if stats.ViewCount != 1 || stats.EmbedViewCount != 0 {
+		t.Fatalf("stats = %+v, want one deduplicated view", stats)
+	}
+}
@@ -0,0 +1,484 @@
+// APEX.BUILD Community Share Cards
+// Status badges and Open Graph preview images for public projects
+
+package community
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Open Graph images use the size social networks crop to
+	shareCardWidth  = 1200
+	shareCardHeight = 630
+
+	shareCardCacheTTL     = 10 * time.Minute
+	shareCardCacheEntries = 256
+)
+
+var (
+	cardBackground = color.RGBA{0x0d, 0x11, 0x17, 0xff}
+	cardAccent     = color.RGBA{0x63, 0x66, 0xf1, 0xff}
+	cardText       = color.RGBA{0xe6, 0xed, 0xf3, 0xff}
+	cardMuted      = color.RGBA{0x8b, 0x94, 0x9e, 0xff}
+)
+
+// Badge colors, matching the shields.io palette READMEs already use
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+	badgeGrey   = "#9f9f9f"
+)
+
+// shareCard is the content drawn into an Open Graph image
+type shareCard struct {
+	Title       string
+	Subtitle    string
+	Description string
+	Footer      string
+	Tag         string
+}
+
+// cachedCard is a rendered badge or image
+type cachedCard struct {
+	body        []byte
+	contentType string
+	etag        string
+	expiresAt   time.Time
+}
+
+// shareCardCache keeps rendered cards in memory so crawlers and README
+// views do not re-render them on every request
+type shareCardCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedCard
+}
+
+func newShareCardCache() *shareCardCache {
+	return &shareCardCache{entries: make(map[string]*cachedCard)}
+}
+
+// get returns the cached card for key, rendering and storing it on a miss
+func (sc *shareCardCache) get(key, contentType string, render func() ([]byte, error)) (*cachedCard, error) {
+	now := time.Now()
+	sc.mu.Lock()
+	if card, ok := sc.entries[key]; ok && now.Before(card.expiresAt) {
+		sc.mu.Unlock()
+		return card, nil
+	}
+	sc.mu.Unlock()
+
+	body, err := render()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	card := &cachedCard{
+		body:        body,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		expiresAt:   now.Add(shareCardCacheTTL),
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.entries) >= shareCardCacheEntries {
+		for k, existing := range sc.entries {
+			if now.After(existing.expiresAt) {
+				delete(sc.entries, k)
+			}
+		}
+		// Still full: drop an arbitrary entry
+		for k := range sc.entries {
+			if len(sc.entries) < shareCardCacheEntries {
+				break
+			}
+			delete(sc.entries, k)
+		}
+	}
+	sc.entries[key] = card
+	return card, nil
+}
+
+// serveCard writes a cached card, answering conditional requests with 304
+func serveCard(c *gin.Context, card *cachedCard, maxAge time.Duration) {
+	c.Header("ETag", card.etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	if match := c.GetHeader("If-None-Match"); match != "" && match == card.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, card.contentType, card.body)
+}
+
+// badgeTextWidth estimates the rendered width of badge text in the 11px
+// Verdana badges use
+func badgeTextWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ijl.,:;'| ", r):
+			width += 4
+		case strings.ContainsRune("mwMW", r):
+			width += 10
+		case r >= 'A' && r <= 'Z':
+			width += 8
+		default:
+			width += 7
+		}
+	}
+	return width
+}
+
+// renderBadge draws a flat status badge as SVG
+func renderBadge(label, message, fill string) []byte {
+	labelWidth := badgeTextWidth(label) + 12
+	messageWidth := badgeTextWidth(message) + 12
+	total := labelWidth + messageWidth
+	label = template.HTMLEscapeString(label)
+	message = template.HTMLEscapeString(message)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, total, label, message)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, message)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, total)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		labelWidth, labelWidth, messageWidth, fill, total)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, labelWidth/2, label, labelWidth/2, label)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, labelWidth+messageWidth/2, message, labelWidth+messageWidth/2, message)
+	b.WriteString(`</g></svg>`)
+	return []byte(b.String())
+}
+
+// buildBadgeStatus describes a project's most recent finished build
+func (h *CommunityHandler) buildBadgeStatus(projectID uint) (string, string) {
+	var build models.CompletedBuild
+	if err := h.DB.Select("status").Where("project_id = ?", projectID).Order("created_at DESC").First(&build).Error; err != nil {
+		return "unknown", badgeGrey
+	}
+	switch build.Status {
+	case "completed":
+		return "passing", badgeGreen
+	case "failed":
+		return "failing", badgeRed
+	default:
+		return build.Status, badgeGrey
+	}
+}
+
+// deployBadgeStatus describes a project's most recent deployment
+func (h *CommunityHandler) deployBadgeStatus(projectID uint) (string, string) {
+	var deployment hosting.NativeDeployment
+	err := h.DB.Select("status").
+		Where("project_id = ? AND status <> ?", projectID, hosting.StatusDeleted).
+		Order("created_at DESC").
+		First(&deployment).Error
+	if err != nil {
+		return "not deployed", badgeGrey
+	}
+	switch deployment.Status {
+	case hosting.StatusRunning:
+		return "live", badgeGreen
+	case hosting.StatusFailed:
+		return "failed", badgeRed
+	case hosting.StatusStopped:
+		return "stopped", badgeGrey
+	default:
+		return "deploying", badgeYellow
+	}
+}
+
+// GetProjectBadge returns a build or deployment status badge for a public
+// project. Unknown projects get a "not found" badge so README images never
+// break.
+// GET /api/v1/project/:username/:project/badges/:badge (build.svg, deploy.svg)
+func (h *CommunityHandler) GetProjectBadge(c *gin.Context) {
+	kind := strings.TrimSuffix(c.Param("badge"), ".svg")
+	if kind != "build" && kind != "deploy" {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   "Unknown badge",
+			Code:    "BADGE_NOT_FOUND",
+		})
+		return
+	}
+	label := kind
+	if custom := strings.TrimSpace(c.Query("label")); custom != "" && len(custom) <= 40 {
+		label = custom
+	}
+
+	var project models.Project
+	err := h.DB.Select("projects.id").Joins("JOIN users ON users.id = projects.owner_id").
+		Where("users.username = ? AND projects.name = ? AND projects.is_public = ?", c.Param("username"), c.Param("project"), true).
+		First(&project).Error
+
+	message, fill := "not found", badgeGrey
+	if err == nil {
+		if kind == "build" {
+			message, fill = h.buildBadgeStatus(project.ID)
+		} else {
+			message, fill = h.deployBadgeStatus(project.ID)
+		}
+	}
+
+	// Badges are cheap to draw; the cache only spares repeat renders
+	card, err := h.cards.get("badge:"+label+":"+message+":"+fill, "image/svg+xml; charset=utf-8", func() ([]byte, error) {
+		return renderBadge(label, message, fill), nil
+	})
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	serveCard(c, card, 2*time.Minute)
+}
+
+// truncateCardText shortens s to at most n characters
+func truncateCardText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n-3])) + "..."
+}
+
+// wrapCardText word-wraps s into at most maxLines lines of maxChars
+func wrapCardText(s string, maxChars, maxLines int) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(s) {
+		word = truncateCardText(word, maxChars)
+		switch {
+		case current == "":
+			current = word
+		case len([]rune(current))+1+len([]rune(word)) <= maxChars:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+		if len(lines) == maxLines {
+			break
+		}
+	}
+	if current != "" && len(lines) < maxLines {
+		lines = append(lines, current)
+	}
+	if len(lines) == maxLines && strings.Join(lines, " ") != strings.Join(strings.Fields(s), " ") {
+		lines[maxLines-1] = truncateCardText(lines[maxLines-1]+" ...", maxChars)
+	}
+	return lines
+}
+
+// renderShareCard draws an Open Graph image as PNG
+func renderShareCard(card shareCard) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, shareCardWidth, shareCardHeight))
+	fillRect(img, 0, 0, shareCardWidth, shareCardHeight, cardBackground)
+	fillRect(img, 0, 0, shareCardWidth, 12, cardAccent)
+
+	const margin = 80
+	usable := shareCardWidth - 2*margin
+	drawText(img, margin, 60, "APEX.BUILD", 4, cardAccent)
+
+	// Shrink long titles before truncating them
+	title := card.Title
+	scale := 10
+	for scale > 6 && textWidth(title, scale) > usable {
+		scale -= 2
+	}
+	title = truncateCardText(title, usable/((glyphWidth+1)*scale))
+	drawText(img, margin, 140, title, scale, cardText)
+
+	if card.Subtitle != "" {
+		drawText(img, margin, 150+glyphHeight*scale+24, card.Subtitle, 4, cardMuted)
+	}
+	for i, line := range wrapCardText(card.Description, usable/((glyphWidth+1)*4), 3) {
+		drawText(img, margin, 330+i*48, line, 4, cardText)
+	}
+
+	fillRect(img, margin, 510, usable, 2, color.RGBA{0x30, 0x36, 0x3d, 0xff})
+	drawText(img, margin, 545, card.Footer, 4, cardMuted)
+	if card.Tag != "" {
+		drawText(img, shareCardWidth-margin-textWidth(card.Tag, 4), 545, card.Tag, 4, cardAccent)
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// projectShareCard loads a public project and the card describing it
+func (h *CommunityHandler) projectShareCard(c *gin.Context) (*models.Project, shareCard, string, bool) {
+	project, ok := h.findPublicProject(c)
+	if !ok {
+		return nil, shareCard{}, "", false
+	}
+	var stats ProjectStats
+	h.DB.Where("project_id = ?", project.ID).First(&stats)
+
+	card := shareCard{
+		Title:       project.Name,
+		Subtitle:    "@" + c.Param("username"),
+		Description: project.Description,
+		Footer:      fmt.Sprintf("%d stars   %d forks   %d views", stats.StarCount, stats.ForkCount, stats.ViewCount),
+		Tag:         project.Language,
+	}
+	key := fmt.Sprintf("project:%d:%d:%d:%d", project.ID, project.UpdatedAt.Unix(), stats.StarCount, stats.ForkCount)
+	return project, card, key, true
+}
+
+// GetProjectShareImage returns a public project's Open Graph image
+// GET /api/v1/project/:username/:project/og.png
+func (h *CommunityHandler) GetProjectShareImage(c *gin.Context) {
+	_, card, key, ok := h.projectShareCard(c)
+	if !ok {
+		return
+	}
+	rendered, err := h.cards.get(key, "image/png", func() ([]byte, error) {
+		return renderShareCard(card)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to render share image",
+			Code:    "RENDER_FAILED",
+		})
+		return
+	}
+	serveCard(c, rendered, time.Hour)
+}
+
+// GetCategoryShareImage returns the Open Graph image for a community
+// category listing
+// GET /api/v1/explore/category/:slug/og.png
+func (h *CommunityHandler) GetCategoryShareImage(c *gin.Context) {
+	var category ProjectCategory
+	if err := h.DB.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   "Category not found",
+			Code:    "CATEGORY_NOT_FOUND",
+		})
+		return
+	}
+
+	var count int64
+	h.DB.Model(&ProjectCategoryAssignment{}).
+		Joins("JOIN projects ON projects.id = project_category_assignments.project_id").
+		Where("project_category_assignments.category_id = ? AND projects.is_public = ? AND projects.deleted_at IS NULL", category.ID, true).
+		Count(&count)
+
+	card := shareCard{
+		Title:       category.Name,
+		Subtitle:    "Community projects",
+		Description: category.Description,
+		Footer:      fmt.Sprintf("%d public projects", count),
+		Tag:         "explore",
+	}
+	key := fmt.Sprintf("category:%d:%d", category.ID, count)
+	rendered, err := h.cards.get(key, "image/png", func() ([]byte, error) {
+		return renderShareCard(card)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to render share image",
+			Code:    "RENDER_FAILED",
+		})
+		return
+	}
+	serveCard(c, rendered, time.Hour)
+}
+
+// projectShareLinks lists the share image, share page and README badges
+// for a public project
+func projectShareLinks(c *gin.Context, username, projectName string) gin.H {
+	base := fmt.Sprintf("%s/api/v1/project/%s/%s", publicAPIBaseURL(c), url.PathEscape(username), url.PathEscape(projectName))
+	coverURL := publicProjectURL(username, projectName)
+	badges := gin.H{}
+	for _, kind := range []string{"build", "deploy"} {
+		badgeURL := base + "/badges/" + kind + ".svg"
+		badges[kind] = gin.H{
+			"url":      badgeURL,
+			"markdown": fmt.Sprintf("[![%s](%s)](%s)", kind, badgeURL, coverURL),
+		}
+	}
+	return gin.H{
+		"cover_url":      coverURL,
+		"share_page_url": base + "/share",
+		"og_image_url":   base + "/og.png",
+		"badges":         badges,
+	}
+}
+
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="APEX.BUILD">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.CoverURL}}">
+<meta property="og:image" content="{{.ImageURL}}">
+<meta property="og:image:width" content="1200">
+<meta property="og:image:height" content="630">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta name="twitter:image" content="{{.ImageURL}}">
+<link rel="canonical" href="{{.CoverURL}}">
+<meta http-equiv="refresh" content="0; url={{.CoverURL}}">
+</head>
+<body><a href="{{.CoverURL}}">{{.Title}}</a></body>
+</html>
+`))
+
+// GetProjectSharePage serves the Open Graph tags social networks read when
+// a project link is shared, then sends visitors on to the cover page
+// GET /api/v1/project/:username/:project/share
+func (h *CommunityHandler) GetProjectSharePage(c *gin.Context) {
+	project, card, _, ok := h.projectShareCard(c)
+	if !ok {
+		return
+	}
+	links := projectShareLinks(c, c.Param("username"), project.Name)
+	description := card.Description
+	if description == "" {
+		description = fmt.Sprintf("A %s project by %s on APEX.BUILD", project.Language, card.Subtitle)
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = sharePageTemplate.Execute(c.Writer, gin.H{
+		"Title":       fmt.Sprintf("%s/%s", c.Param("username"), project.Name),
+		"Description": description,
+		"CoverURL":    links["cover_url"],
+		"ImageURL":    links["og_image_url"],
+	})
+}
@@ -0,0 +1,104 @@
+package community
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestWrapCardText(t *testing.T) {
+	lines := wrapCardText("a tiny tool that turns markdown notes into a searchable static site with tags", 20, 2)
+	if len(lines) != 2 || lines[0] != "a tiny tool that" || !strings.HasSuffix(lines[1], "...") {
+		t.Fatalf("lines = %q", lines)
+	}
+	for _, line := range lines {
+		if len(line) > 20 {
+			t.Fatalf("line %q is longer than 20 characters", line)
+		}
+	}
+	if lines := wrapCardText("short", 20, 3); len(lines) != 1 || lines[0] != "short" {
+		t.Fatalf("lines = %q", lines)
+	}
+}
+
+func TestShareCardEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.CompletedBuild{}, &hosting.NativeDeployment{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate community: %v", err)
+	}
+
+	owner := &models.User{Username: "grace", Email: "grace@example.com", PasswordHash: "x"}
+	if err := db.Create(owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	project := &models.Project{Name: "compiler", Description: "A small compiler for a toy language", Language: "go", OwnerID: owner.ID, IsPublic: true}
+	if err := db.Create(project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	if err := db.Create(&models.CompletedBuild{BuildID: "b-1", UserID: owner.ID, ProjectID: &project.ID, Status: "failed"}).Error; err != nil {
+		t.Fatalf("create build: %v", err)
+	}
+	if err := db.Create(&hosting.NativeDeployment{ID: "dep-1", ProjectID: project.ID, UserID: owner.ID, Subdomain: "compiler", Status: hosting.StatusRunning}).Error; err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+
+	router := gin.New()
+	NewCommunityHandler(db).RegisterRoutes(router.Group("/api/v1"))
+	get := func(target, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	build := get("/api/v1/project/grace/compiler/badges/build.svg", "")
+	if build.Code != http.StatusOK || !strings.Contains(build.Body.String(), "failing") || !strings.HasPrefix(build.Header().Get("Content-Type"), "image/svg+xml") {
+		t.Fatalf("build badge = %d %s", build.Code, build.Body.String())
+	}
+	if w := get("/api/v1/project/grace/compiler/badges/deploy.svg?label=demo", ""); !strings.Contains(w.Body.String(), "demo: live") {
+		t.Fatalf("deploy badge = %s", w.Body.String())
+	}
+	if w := get("/api/v1/project/grace/missing/badges/build.svg", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "not found") {
+		t.Fatalf("missing project badge = %d %s", w.Code, w.Body.String())
+	}
+
+	image := get("/api/v1/project/grace/compiler/og.png", "")
+	if image.Code != http.StatusOK {
+		t.Fatalf("og image = %d %s", image.Code, image.Body.String())
+	}
+	decoded, err := png.Decode(bytes.NewReader(image.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("decode og image: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != shareCardWidth || b.Dy() != shareCardHeight {
+		t.Fatalf("og image is %dx%d", b.Dx(), b.Dy())
+	}
+	if w := get("/api/v1/project/grace/compiler/og.png", image.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Fatalf("conditional og image = %d, want 304", w.Code)
+	}
+
+	share := get("/api/v1/project/grace/compiler/share", "")
+	if share.Code != http.StatusOK || !strings.Contains(share.Body.String(), `property="og:image" content="http://example.com/api/v1/project/grace/compiler/og.png"`) {
+		t.Fatalf("share page = %d %s", share.Code, share.Body.String())
+	}
+}
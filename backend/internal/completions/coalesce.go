@@ -0,0 +1,105 @@
+package completions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultDebounce is how long an automatic completion waits for the next
+// keystroke before it is sent to a provider.
+const DefaultDebounce = 75 * time.Millisecond
+
+// coalescer cuts provider calls made while the user is typing. Automatic
+// requests for the same user and file are debounced so only the newest one
+// proceeds, and identical requests in flight share one provider call.
+type coalescer struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	seq      uint64
+	latest   map[string]uint64
+	inflight map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done chan struct{}
+	resp *CompletionResponse
+	err  error
+}
+
+func newCoalescer(delay time.Duration) *coalescer {
+	return &coalescer{
+		delay:    delay,
+		latest:   make(map[string]uint64),
+		inflight: make(map[string]*coalescedCall),
+	}
+}
+
+// debounceKey identifies the editor a request came from.
+func debounceKey(userID uint, req *CompletionRequest) string {
+	if req.FileID != 0 {
+		return fmt.Sprintf("%d:f%d", userID, req.FileID)
+	}
+	return fmt.Sprintf("%d:p%d:%s", userID, req.ProjectID, req.FilePath)
+}
+
+// debounce waits out the delay and reports whether a newer request for key
+// arrived meanwhile, in which case the caller should drop this one.
+func (c *coalescer) debounce(ctx context.Context, key string) (superseded bool, err error) {
+	if c.delay <= 0 {
+		return false, nil
+	}
+	c.mu.Lock()
+	c.seq++
+	mine := c.seq
+	c.latest[key] = mine
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		c.release(key, mine)
+		return false, ctx.Err()
+	case <-timer.C:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.latest[key] != mine {
+		return true, nil
+	}
+	delete(c.latest, key)
+	return false, nil
+}
+
+func (c *coalescer) release(key string, seq uint64) {
+	c.mu.Lock()
+	if c.latest[key] == seq {
+		delete(c.latest, key)
+	}
+	c.mu.Unlock()
+}
+
+// do runs fn once for concurrent callers with the same key. shared is true
+// for callers that received another caller's result.
+func (c *coalescer) do(key string, fn func() (*CompletionResponse, error)) (resp *CompletionResponse, shared bool, err error) {
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.resp, true, call.err
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.resp, call.err = fn()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+	return call.resp, false, call.err
+}
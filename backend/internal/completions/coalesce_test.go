@@ -0,0 +1,67 @@
+package completions
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescerDebounceKeepsNewestRequest(t *testing.T) {
+	c := newCoalescer(30 * time.Millisecond)
+	ctx := context.Background()
+
+	results := make(chan bool, 1)
+	go func() {
+		superseded, _ := c.debounce(ctx, "1:f9")
+		results <- superseded
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	superseded, err := c.debounce(ctx, "1:f9")
+	if err != nil || superseded {
+		t.Fatalf("newest request = %v, %v; want it to proceed", superseded, err)
+	}
+	if !<-results {
+		t.Fatal("older request for the same editor should be superseded")
+	}
+
+	// Other editors are independent
+	if superseded, _ := c.debounce(ctx, "2:f9"); superseded {
+		t.Fatal("a different user's request was superseded")
+	}
+}
+
+func TestCoalescerDoSharesInFlightCalls(t *testing.T) {
+	c := newCoalescer(0)
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, shared, err := c.do("key", func() (*CompletionResponse, error) {
+				calls.Add(1)
+				<-release
+				return &CompletionResponse{ID: "one"}, nil
+			})
+			if err != nil || resp.ID != "one" {
+				t.Errorf("do = %v, %v", resp, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || sharedCount.Load() != 2 {
+		t.Fatalf("calls = %d shared = %d; want one call shared by two waiters", calls.Load(), sharedCount.Load())
+	}
+}
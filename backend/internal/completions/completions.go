@@ -56,6 +56,18 @@ type CompletionContext struct {
 	RelatedFiles   []RelatedFile     `json:"related_files,omitempty"`
 	Framework      string            `json:"framework,omitempty"`
 	Dependencies   map[string]string `json:"dependencies,omitempty"`
+	// Definitions of identifiers near the cursor. Filled from the project's
+	// symbol index when the client sends none.
+	Definitions []SymbolDefinition `json:"definitions,omitempty"`
+}
+
+// SymbolDefinition is a declaration the code near the cursor refers to
+type SymbolDefinition struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Signature string `json:"signature,omitempty"`
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
 }
 
 // RecentEdit represents a recent edit in the file
@@ -82,6 +94,9 @@ type CompletionResponse struct {
 	ProcessingTime int64            `json:"processing_time_ms"`
 	CachedHit      bool             `json:"cached_hit"`
 	Usage          *CompletionUsage `json:"usage,omitempty"`
+	FIM            bool             `json:"fim,omitempty"`        // answered by a fill-in-the-middle model
+	Superseded     bool             `json:"superseded,omitempty"` // dropped for a newer request from the same editor
+	Fast           bool             `json:"fast,omitempty"`       // answered by the latency-optimized model
 }
 
 // CompletionItem represents a single completion suggestion
@@ -175,8 +190,39 @@ type CompletionService struct {
 	metrics *CompletionMetrics
 
 	usageTracker *usage.Tracker
+
+	instructions ProjectInstructions
+
+	// Context harvesting
+	access  ProjectAccess
+	symbols SymbolDefinitions
+
+	coalescer *coalescer
+
+	// Acceptance telemetry and model auto-tuning
+	issued *issuedCompletions
+	tuner  *modelTuner
+
+	// Latency-optimized routing
+	settings sync.Map // user ID -> *CompletionSettings
+	fast     *fastModels
+}
+
+// ProjectInstructions renders a project's AI instructions (apex.md and
+// organization instructions). Implemented by instructions.Service.
+type ProjectInstructions interface {
+	PromptContext(ctx context.Context, userID, projectID uint) (string, error)
 }
 
+// maxCompletionInstructionsChars keeps instructions from crowding out the
+// code context in short completion prompts.
+const maxCompletionInstructionsChars = 4000
+
+const (
+	maxPrefixChars = 2000 // code before the cursor sent to the model
+	maxSuffixChars = 500  // code after the cursor sent to the model
+)
+
 // CompletionRateLimiter manages completion rate limits
 type CompletionRateLimiter struct {
 	mu       sync.RWMutex
@@ -197,6 +243,10 @@ type CompletionMetrics struct {
 	totalLatency     int64
 	cacheHits        int64
 	cacheMisses      int64
+	coalesced        int64
+	superseded       int64
+	fastHits         int64
+	fastFallbacks    int64
 	providerLatency  map[string]int64
 	providerRequests map[string]int64
 }
@@ -219,10 +269,14 @@ func NewCompletionService(db *gorm.DB, aiRouter *ai.AIRouter, byokManager *ai.BY
 			providerLatency:  make(map[string]int64),
 			providerRequests: make(map[string]int64),
 		},
+		coalescer: newCoalescer(DefaultDebounce),
+		issued:    newIssuedCompletions(),
+		fast:      newFastModels(),
 	}
+	svc.tuner = newModelTuner(svc)
 
 	// Run migrations
-	db.AutoMigrate(&CompletionCache{})
+	db.AutoMigrate(&CompletionCache{}, &CompletionEvent{}, &CompletionSettings{})
 
 	// Start cache cleanup worker
 	go svc.cacheCleanupWorker()
@@ -234,32 +288,106 @@ func (s *CompletionService) SetUsageTracker(tracker *usage.Tracker) {
 	s.usageTracker = tracker
 }
 
+// SetProjectInstructions wires project instructions into completion prompts.
+func (s *CompletionService) SetProjectInstructions(source ProjectInstructions) {
+	s.instructions = source
+}
+
+// SetContextSources enables harvesting of symbol definitions and sibling
+// files. A nil access limits harvesting to the project owner.
+func (s *CompletionService) SetContextSources(access ProjectAccess, definitions SymbolDefinitions) {
+	s.access = access
+	s.symbols = definitions
+}
+
+// SetDebounce sets how long automatic completions wait for the next
+// keystroke. Zero disables debouncing.
+func (s *CompletionService) SetDebounce(delay time.Duration) {
+	s.coalescer = newCoalescer(delay)
+}
+
+func (s *CompletionService) projectInstructions(ctx context.Context, userID uint, req *CompletionRequest) string {
+	if s.instructions == nil || req.ProjectID == 0 {
+		return ""
+	}
+	block, err := s.instructions.PromptContext(ctx, userID, req.ProjectID)
+	if err != nil {
+		return ""
+	}
+	block = strings.TrimSpace(block)
+	if len(block) > maxCompletionInstructionsChars {
+		block = block[:maxCompletionInstructionsChars] + "\n..."
+	}
+	return block
+}
+
 // GetCompletions returns AI-powered code completions
 func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req *CompletionRequest) (*CompletionResponse, error) {
 	startTime := time.Now()
 
+	// Debounce typing-driven requests; only the newest per editor proceeds
+	if req.TriggerKind == TriggerAutomatic || req.TriggerKind == TriggerCharacter {
+		superseded, err := s.coalescer.debounce(ctx, debounceKey(userID, req))
+		if err != nil {
+			return nil, err
+		}
+		if superseded {
+			s.metrics.RecordSuperseded()
+			return &CompletionResponse{
+				ID:             uuid.New().String(),
+				Completions:    []CompletionItem{},
+				ProcessingTime: time.Since(startTime).Milliseconds(),
+				Superseded:     true,
+			}, nil
+		}
+	}
+
 	// Check rate limit
 	if !s.rateLimiter.Allow(userID) {
 		return nil, fmt.Errorf("rate limit exceeded, please try again later")
 	}
 
+	instructions := s.projectInstructions(ctx, userID, req)
+
 	// Generate cache key
-	cacheKey := s.generateCacheKey(req)
+	cacheKey := s.generateCacheKey(req, instructions)
 
 	// Check cache
 	if s.cacheEnabled {
 		if cached, ok := s.cache.Load(cacheKey); ok {
-			response := cached.(*CompletionResponse)
+			response := *cached.(*CompletionResponse)
 			response.CachedHit = true
 			response.ProcessingTime = time.Since(startTime).Milliseconds()
 			s.metrics.RecordCacheHit()
-			return response, nil
+			s.issued.remember(req, &response)
+			return &response, nil
 		}
 	}
 	s.metrics.RecordCacheMiss()
 
+	// Identical requests in flight share one provider call
+	response, shared, err := s.coalescer.do(cacheKey, func() (*CompletionResponse, error) {
+		return s.complete(ctx, userID, req, instructions, cacheKey, startTime)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.issued.remember(req, response)
+	if shared {
+		s.metrics.RecordCoalesced()
+		copied := *response
+		copied.ProcessingTime = time.Since(startTime).Milliseconds()
+		return &copied, nil
+	}
+	return response, nil
+}
+
+// complete harvests context and asks the provider for a completion
+func (s *CompletionService) complete(ctx context.Context, userID uint, req *CompletionRequest, instructions, cacheKey string, startTime time.Time) (*CompletionResponse, error) {
+	s.harvestContext(ctx, userID, req)
+
 	// Build AI prompt
-	prompt := s.buildCompletionPrompt(req)
+	prompt := s.buildCompletionPrompt(req, instructions)
 
 	// Set default parameters
 	maxTokens := req.MaxTokens
@@ -274,12 +402,18 @@ func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req
 	// Request completion from AI
 	aiReq := &ai.AIRequest{
 		Capability:  ai.CapabilityCodeCompletion,
+		ModelScope:  ai.ModelScopeCompletions,
 		Prompt:      prompt,
 		Code:        req.Prefix,
 		Language:    req.Language,
 		MaxTokens:   maxTokens,
 		Temperature: float32(temperature),
 		UserID:      fmt.Sprintf("%d", userID),
+		Stop:        s.getStopTokens(req),
+		FIM: &ai.FIMInput{
+			Prefix: s.buildFIMPrefix(req),
+			Suffix: truncateSuffix(req.Suffix),
+		},
 	}
 
 	targetRouter := s.aiRouter
@@ -291,11 +425,31 @@ func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req
 		}
 	}
 
+	available := func(choice ModelChoice) bool {
+		_, ok := targetRouter.GetClient(ai.AIProvider(choice.Provider))
+		return ok
+	}
+
+	// Short completions try a small fast model first
+	route := s.planFastRoute(ctx, userID, req, available)
+
+	// Route platform completions to the model users accept most for this
+	// language. BYOK users keep their own model preferences.
+	if !isBYOK {
+		if choice := s.tuner.choose(ctx, req.Language, available); choice != nil {
+			aiReq.Provider = ai.AIProvider(choice.Provider)
+			aiReq.Model = choice.Model
+		}
+	}
+
 	// Reserve credits before making the AI call
 	var reservation *ai.CreditReservation
 	if s.byokManager != nil && userID > 0 {
 		powerMode := pricing.ModeFast
 		estimateProvider := string(targetRouter.GetDefaultProvider(aiReq.Capability))
+		if aiReq.Provider != "" {
+			estimateProvider = string(aiReq.Provider)
+		}
 		estimatedCost := s.byokManager.EstimateCost(
 			estimateProvider,
 			aiReq.Model,
@@ -316,7 +470,7 @@ func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req
 		}
 	}
 
-	aiResp, err := targetRouter.Generate(ctx, aiReq)
+	aiResp, aiReq, fast, err := s.generate(ctx, targetRouter, aiReq, route)
 	if err != nil {
 		if s.byokManager != nil && reservation != nil {
 			_ = s.byokManager.FinalizeCredits(reservation, 0)
@@ -325,7 +479,8 @@ func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req
 	}
 
 	// Parse completions from response
-	completions := s.parseCompletions(aiResp.Content, req)
+	fim, _ := aiResp.Metadata["fim"].(bool)
+	completions := s.parseCompletions(aiResp.Content, req, fim)
 
 	// Extract usage info
 	var usage *CompletionUsage
@@ -353,10 +508,12 @@ func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req
 		ID:             uuid.New().String(),
 		Completions:    completions,
 		Provider:       string(aiResp.Provider),
-		Model:          string(aiResp.Provider),
+		Model:          ai.GetModelUsed(aiResp, aiReq),
 		ProcessingTime: time.Since(startTime).Milliseconds(),
 		CachedHit:      false,
 		Usage:          usage,
+		FIM:            fim,
+		Fast:           fast,
 	}
 
 	// Cache response
@@ -405,11 +562,14 @@ func (s *CompletionService) GetCompletions(ctx context.Context, userID uint, req
 }
 
 // buildCompletionPrompt constructs the AI prompt for completions
-func (s *CompletionService) buildCompletionPrompt(req *CompletionRequest) string {
+func (s *CompletionService) buildCompletionPrompt(req *CompletionRequest, instructions string) string {
 	var sb strings.Builder
 
 	// System context
 	sb.WriteString("You are an AI code completion assistant. Complete the code naturally.\n")
+	if instructions != "" {
+		sb.WriteString(instructions + "\n\n")
+	}
 	sb.WriteString(fmt.Sprintf("Language: %s\n", req.Language))
 
 	if req.Context.Framework != "" {
@@ -424,6 +584,14 @@ func (s *CompletionService) buildCompletionPrompt(req *CompletionRequest) string
 		}
 	}
 
+	// Add definitions of identifiers near the cursor
+	if len(req.Context.Definitions) > 0 {
+		sb.WriteString("\nDefinitions in scope:\n")
+		for _, def := range req.Context.Definitions {
+			sb.WriteString(fmt.Sprintf("- %s\n", definitionLine(def)))
+		}
+	}
+
 	// Add related file context
 	if len(req.Context.RelatedFiles) > 0 {
 		sb.WriteString("\nRelated code context:\n")
@@ -436,60 +604,181 @@ func (s *CompletionService) buildCompletionPrompt(req *CompletionRequest) string
 	sb.WriteString("Complete the following code. Only return the completion, nothing else:\n\n")
 
 	// Add code context
-	if len(req.Prefix) > 2000 {
-		// Trim prefix to last 2000 chars
+	if len(req.Prefix) > maxPrefixChars {
 		sb.WriteString("...\n")
-		sb.WriteString(req.Prefix[len(req.Prefix)-2000:])
-	} else {
-		sb.WriteString(req.Prefix)
 	}
+	sb.WriteString(truncatePrefix(req.Prefix))
 
 	// Mark cursor position
 	sb.WriteString("█") // Cursor marker
 
 	// Add suffix context (limited)
 	if req.Suffix != "" {
-		suffixPreview := req.Suffix
-		if len(suffixPreview) > 500 {
-			suffixPreview = suffixPreview[:500] + "\n..."
+		sb.WriteString(truncateSuffix(req.Suffix))
+		if len(req.Suffix) > maxSuffixChars {
+			sb.WriteString("\n...")
 		}
-		sb.WriteString(suffixPreview)
 	}
 
 	return sb.String()
 }
 
-// getStopTokens returns appropriate stop tokens for the language
-func (s *CompletionService) getStopTokens(req *CompletionRequest) []string {
-	base := []string{"\n\n", "```", "// ", "/* ", "# "}
-
-	switch req.Language {
-	case "python":
-		return append(base, "\ndef ", "\nclass ", "\nif __name__")
-	case "javascript", "typescript":
-		return append(base, "\nfunction ", "\nclass ", "\nconst ", "\nexport ")
-	case "go":
-		return append(base, "\nfunc ", "\ntype ", "\nvar ", "\nconst ")
-	case "rust":
-		return append(base, "\nfn ", "\nstruct ", "\nimpl ", "\nmod ")
-	case "java":
-		return append(base, "\npublic ", "\nprivate ", "\nclass ", "\ninterface ")
+// buildFIMPrefix builds the prefix for fill-in-the-middle models. These are
+// base code models, so harvested context goes in as leading comments rather
+// than instructions.
+func (s *CompletionService) buildFIMPrefix(req *CompletionRequest) string {
+	comment := lineComment(req.Language)
+	var sb strings.Builder
+	writeComment := func(text string) {
+		for _, line := range strings.Split(text, "\n") {
+			sb.WriteString(comment + " " + line + "\n")
+		}
+	}
+
+	if req.FilePath != "" {
+		writeComment("Path: " + req.FilePath)
+	}
+	if len(req.Context.Definitions) > 0 {
+		writeComment("Definitions in scope:")
+		for _, def := range req.Context.Definitions {
+			writeComment("  " + definitionLine(def))
+		}
+	}
+	for _, file := range req.Context.RelatedFiles[:min(3, len(req.Context.RelatedFiles))] {
+		writeComment("Compare this snippet from " + file.Path + ":")
+		writeComment(file.Snippet)
+	}
+
+	sb.WriteString(truncatePrefix(req.Prefix))
+	return sb.String()
+}
+
+// definitionLine renders a definition for a prompt
+func definitionLine(def SymbolDefinition) string {
+	text := def.Signature
+	if text == "" {
+		text = def.Kind + " " + def.Name
+	}
+	return fmt.Sprintf("%s (%s:%d)", text, def.Path, def.Line)
+}
+
+// truncatePrefix keeps the code nearest the cursor, starting on a line
+// boundary where possible
+func truncatePrefix(prefix string) string {
+	if len(prefix) <= maxPrefixChars {
+		return prefix
+	}
+	cut := prefix[len(prefix)-maxPrefixChars:]
+	if i := strings.IndexByte(cut, '\n'); i >= 0 && i < len(cut)-1 {
+		cut = cut[i+1:]
+	}
+	return cut
+}
+
+func truncateSuffix(suffix string) string {
+	if len(suffix) <= maxSuffixChars {
+		return suffix
+	}
+	return suffix[:maxSuffixChars]
+}
+
+// lineComment returns the line comment marker for a language
+func lineComment(language string) string {
+	switch language {
+	case "python", "ruby", "shell", "bash", "sh", "yaml", "toml", "r", "perl", "elixir", "dockerfile":
+		return "#"
+	case "sql", "lua", "haskell":
+		return "--"
 	default:
-		return base
+		return "//"
+	}
+}
+
+// languageStopTokens end a completion where the next top-level declaration
+// would start, most useful first; providers keep only the first few.
+var languageStopTokens = map[string][]string{
+	"python":     {"\ndef ", "\nclass ", "\nif __name__", "\n\n\n"},
+	"javascript": {"\nfunction ", "\nexport ", "\nclass ", "\n\n\n"},
+	"typescript": {"\nfunction ", "\nexport ", "\nclass ", "\ninterface "},
+	"go":         {"\nfunc ", "\ntype ", "\nvar ", "\n\n\n"},
+	"rust":       {"\nfn ", "\npub fn ", "\nimpl ", "\n\n\n"},
+	"java":       {"\npublic ", "\nprivate ", "\nclass ", "\n\n\n"},
+	"kotlin":     {"\nfun ", "\nclass ", "\nobject ", "\n\n\n"},
+	"csharp":     {"\npublic ", "\nprivate ", "\nnamespace ", "\n\n\n"},
+	"swift":      {"\nfunc ", "\nclass ", "\nstruct ", "\n\n\n"},
+	"ruby":       {"\ndef ", "\nclass ", "\nmodule ", "\n\n\n"},
+	"php":        {"\nfunction ", "\nclass ", "\n?>", "\n\n\n"},
+	"elixir":     {"\ndefmodule ", "\n  def ", "\n\n\n"},
+	"c":          {"\n#include", "\n#define", "\n\n\n"},
+	"cpp":        {"\n#include", "\nclass ", "\nnamespace ", "\n\n\n"},
+}
+
+// getStopTokens returns the stop sequences for a request: the client's own
+// if it sent any, otherwise the language's. A cursor in the middle of a line
+// stops at the end of that line.
+func (s *CompletionService) getStopTokens(req *CompletionRequest) []string {
+	if len(req.StopTokens) > 0 {
+		return req.StopTokens
+	}
+
+	language := req.Language
+	switch language {
+	case "javascriptreact", "jsx":
+		language = "javascript"
+	case "typescriptreact", "tsx":
+		language = "typescript"
+	case "c++":
+		language = "cpp"
+	case "c#":
+		language = "csharp"
 	}
+
+	var stops []string
+	if midLine(req.Suffix) {
+		stops = append(stops, "\n")
+	}
+	stops = append(stops, languageStopTokens[language]...)
+	return append(stops, "```")
+}
+
+// midLine reports whether code follows the cursor on its line, ignoring
+// closing brackets and quotes an editor auto-inserts.
+func midLine(suffix string) bool {
+	line, _, _ := strings.Cut(suffix, "\n")
+	return strings.TrimSpace(strings.Trim(line, ")]}'\"`;, \t")) != ""
 }
 
-// parseCompletions parses AI response into completion items
-func (s *CompletionService) parseCompletions(response string, req *CompletionRequest) []CompletionItem {
-	// Clean up response
-	completion := strings.TrimSpace(response)
-	completion = strings.TrimPrefix(completion, "█") // Remove cursor marker if present
+// cutAtStop truncates text at the earliest stop sequence
+func cutAtStop(text string, stops []string) string {
+	end := len(text)
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		if i := strings.Index(text, stop); i >= 0 && i < end {
+			end = i
+		}
+	}
+	return text[:end]
+}
 
-	// Remove any markdown code block markers
-	completion = strings.TrimPrefix(completion, "```"+req.Language)
-	completion = strings.TrimPrefix(completion, "```")
-	completion = strings.TrimSuffix(completion, "```")
-	completion = strings.TrimSpace(completion)
+// parseCompletions parses AI response into completion items. FIM output is
+// the raw middle, so its leading whitespace is kept.
+func (s *CompletionService) parseCompletions(response string, req *CompletionRequest, fim bool) []CompletionItem {
+	var completion string
+	if fim {
+		completion = strings.TrimRight(cutAtStop(response, s.getStopTokens(req)), " \t\n")
+	} else {
+		// Clean up response
+		completion = strings.TrimSpace(response)
+		completion = strings.TrimPrefix(completion, "█") // Remove cursor marker if present
+
+		// Remove any markdown code block markers
+		completion = strings.TrimPrefix(completion, "```"+req.Language)
+		completion = strings.TrimPrefix(completion, "```")
+		completion = strings.TrimSuffix(completion, "```")
+		completion = strings.TrimSpace(cutAtStop(strings.TrimSpace(completion), s.getStopTokens(req)))
+	}
 
 	if completion == "" {
 		return []CompletionItem{}
@@ -565,25 +854,30 @@ func (s *CompletionService) truncateDisplay(text string, maxLen int) string {
 }
 
 // generateCacheKey creates a cache key from the request
-func (s *CompletionService) generateCacheKey(req *CompletionRequest) string {
+func (s *CompletionService) generateCacheKey(req *CompletionRequest, instructions string) string {
 	// Include relevant fields in cache key
-	data := fmt.Sprintf("%d:%s:%s:%d:%d:%s",
+	data := fmt.Sprintf("%d:%s:%s:%s:%d:%d:%s:%s",
 		req.FileID,
 		req.Language,
 		req.Prefix[max(0, len(req.Prefix)-500):], // Last 500 chars of prefix
+		req.Suffix[:min(200, len(req.Suffix))],   // First 200 chars of suffix
 		req.Line,
 		req.Column,
 		req.TriggerKind,
+		instructions,
 	)
 
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
 
-// AcceptCompletion records when a completion is accepted
-func (s *CompletionService) AcceptCompletion(userID uint, completionID string, accepted bool) {
-	// Track acceptance for improving future suggestions
-	// This data can be used to fine-tune or improve ranking
+// AcceptCompletion records a completion as accepted or rejected
+func (s *CompletionService) AcceptCompletion(ctx context.Context, userID uint, completionID string, accepted bool) error {
+	outcome := OutcomeRejected
+	if accepted {
+		outcome = OutcomeAccepted
+	}
+	return s.RecordEvent(ctx, userID, CompletionEventInput{CompletionID: completionID, Outcome: outcome})
 }
 
 // GetInlineCompletion returns a single inline completion (for ghost text)
@@ -650,6 +944,30 @@ func (m *CompletionMetrics) RecordCacheMiss() {
 	m.cacheMisses++
 }
 
+func (m *CompletionMetrics) RecordCoalesced() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.coalesced++
+}
+
+func (m *CompletionMetrics) RecordSuperseded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.superseded++
+}
+
+func (m *CompletionMetrics) RecordFastHit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fastHits++
+}
+
+func (m *CompletionMetrics) RecordFastFallback() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fastFallbacks++
+}
+
 func (m *CompletionMetrics) GetStats() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -670,6 +988,10 @@ func (m *CompletionMetrics) GetStats() map[string]interface{} {
 		"avg_latency_ms":    avgLatency,
 		"cache_hit_rate":    cacheHitRate,
 		"provider_requests": m.providerRequests,
+		"coalesced":         m.coalesced,
+		"superseded":        m.superseded,
+		"fast_hits":         m.fastHits,
+		"fast_fallbacks":    m.fastFallbacks,
 	}
 }
 
@@ -690,6 +1012,9 @@ func (s *CompletionService) cacheCleanupWorker() {
 
 		// Clear database cache
 		s.db.Where("expires_at < ?", cutoff).Delete(&CompletionCache{})
+
+		// Forget completions too old to be reported on
+		s.issued.prune(time.Now())
 	}
 }
 
@@ -0,0 +1,397 @@
+package completions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/ai"
+)
+
+// CompletionMode selects how a user's completions are routed
+type CompletionMode string
+
+const (
+	// ModeAuto sends short completions to a small fast model and
+	// multi-line generations to the standard model
+	ModeAuto CompletionMode = "auto"
+	// ModeFast sends every completion to the fast model first
+	ModeFast CompletionMode = "fast"
+	// ModeQuality always uses the standard model
+	ModeQuality CompletionMode = "quality"
+)
+
+const (
+	// DefaultLatencyTarget is the end-to-end latency fast completions aim for
+	DefaultLatencyTarget = 300 * time.Millisecond
+	minLatencyTarget     = 100 * time.Millisecond
+	maxLatencyTarget     = 2 * time.Second
+
+	// fastDeadlineFactor bounds a fast attempt at a multiple of the target;
+	// past it the request escalates to the standard model
+	fastDeadlineFactor = 2
+	fastMaxTokens      = 64
+
+	// A fast model whose average latency exceeds the target after
+	// fastMinSamples calls, or that fails fastMaxFailures times in a row,
+	// is skipped for fastCooldown.
+	fastMinSamples  = 5
+	fastMaxFailures = 3
+	fastCooldown    = 2 * time.Minute
+	fastEWMAWeight  = 0.2
+)
+
+// DefaultFastModels are tried in order when neither the user nor
+// COMPLETION_FAST_MODELS names a fast model.
+var DefaultFastModels = []ModelChoice{
+	{Provider: string(ai.ProviderOllama), Model: "qwen2.5-coder:1.5b"},
+	{Provider: string(ai.ProviderGPT4), Model: "gpt-4o-mini"},
+	{Provider: string(ai.ProviderGemini), Model: "gemini-2.5-flash-lite"},
+	{Provider: string(ai.ProviderClaude), Model: "claude-haiku-4-5-20251001"},
+}
+
+var (
+	// ErrInvalidMode is returned for completion modes other than auto,
+	// fast and quality
+	ErrInvalidMode = errors.New("mode must be auto, fast or quality")
+	// ErrInvalidLatencyTarget is returned for targets outside 100-2000ms
+	ErrInvalidLatencyTarget = fmt.Errorf("latency_target_ms must be between %d and %d", minLatencyTarget.Milliseconds(), maxLatencyTarget.Milliseconds())
+	// ErrInvalidFastModel is returned when only one of provider and model
+	// is set, or the provider is unknown
+	ErrInvalidFastModel = errors.New("fast_provider and fast_model must be set together with a known provider")
+)
+
+var fastProviders = map[string]bool{
+	string(ai.ProviderClaude):     true,
+	string(ai.ProviderGPT4):       true,
+	string(ai.ProviderGemini):     true,
+	string(ai.ProviderGrok):       true,
+	string(ai.ProviderOllama):     true,
+	string(ai.ProviderOpenRouter): true,
+}
+
+// CompletionSettings are a user's completion routing preferences
+type CompletionSettings struct {
+	UserID          uint           `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Mode            CompletionMode `gorm:"type:varchar(20);default:'auto'" json:"mode"`
+	FastProvider    string         `gorm:"type:varchar(50)" json:"fast_provider,omitempty"`
+	FastModel       string         `gorm:"type:varchar(100)" json:"fast_model,omitempty"`
+	LatencyTargetMs int            `json:"latency_target_ms"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// CompletionSettingsUpdate changes the fields that are set
+type CompletionSettingsUpdate struct {
+	Mode            *CompletionMode `json:"mode"`
+	FastProvider    *string         `json:"fast_provider"`
+	FastModel       *string         `json:"fast_model"`
+	LatencyTargetMs *int            `json:"latency_target_ms"`
+}
+
+// FastModelStatus is the measured latency of a fast model
+type FastModelStatus struct {
+	Provider     string     `json:"provider"`
+	Model        string     `json:"model"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	Samples      int64      `json:"samples"`
+	Failures     int64      `json:"failures"`
+	SkippedUntil *time.Time `json:"skipped_until,omitempty"`
+}
+
+func defaultSettings(userID uint) *CompletionSettings {
+	return &CompletionSettings{UserID: userID, Mode: ModeAuto, LatencyTargetMs: int(DefaultLatencyTarget.Milliseconds())}
+}
+
+func (c *CompletionSettings) latencyTarget() time.Duration {
+	if c.LatencyTargetMs <= 0 {
+		return DefaultLatencyTarget
+	}
+	return time.Duration(c.LatencyTargetMs) * time.Millisecond
+}
+
+// GetSettings returns the user's completion settings, or the defaults
+func (s *CompletionService) GetSettings(ctx context.Context, userID uint) (*CompletionSettings, error) {
+	if cached, ok := s.settings.Load(userID); ok {
+		settings := *cached.(*CompletionSettings)
+		return &settings, nil
+	}
+	var rows []CompletionSettings
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("completions: load settings: %w", err)
+	}
+	settings := defaultSettings(userID)
+	if len(rows) == 1 {
+		settings = &rows[0]
+	}
+	s.settings.Store(userID, settings)
+	copied := *settings
+	return &copied, nil
+}
+
+// UpdateSettings validates and saves changes to the user's settings
+func (s *CompletionService) UpdateSettings(ctx context.Context, userID uint, update CompletionSettingsUpdate) (*CompletionSettings, error) {
+	settings, err := s.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Mode != nil {
+		switch *update.Mode {
+		case ModeAuto, ModeFast, ModeQuality:
+			settings.Mode = *update.Mode
+		default:
+			return nil, ErrInvalidMode
+		}
+	}
+	if update.LatencyTargetMs != nil {
+		target := time.Duration(*update.LatencyTargetMs) * time.Millisecond
+		if target < minLatencyTarget || target > maxLatencyTarget {
+			return nil, ErrInvalidLatencyTarget
+		}
+		settings.LatencyTargetMs = *update.LatencyTargetMs
+	}
+	if update.FastProvider != nil {
+		settings.FastProvider = strings.ToLower(strings.TrimSpace(*update.FastProvider))
+	}
+	if update.FastModel != nil {
+		settings.FastModel = strings.TrimSpace(*update.FastModel)
+	}
+	if (settings.FastProvider == "") != (settings.FastModel == "") ||
+		(settings.FastProvider != "" && !fastProviders[settings.FastProvider]) ||
+		len(settings.FastModel) > 100 {
+		return nil, ErrInvalidFastModel
+	}
+
+	settings.UserID = userID
+	if err := s.db.WithContext(ctx).Save(settings).Error; err != nil {
+		return nil, fmt.Errorf("completions: save settings: %w", err)
+	}
+	stored := *settings
+	s.settings.Store(userID, &stored)
+	return settings, nil
+}
+
+// SetFastModels sets the platform's fast models in preference order
+func (s *CompletionService) SetFastModels(models []ModelChoice) {
+	if len(models) == 0 {
+		models = DefaultFastModels
+	}
+	s.fast.mu.Lock()
+	s.fast.models = models
+	s.fast.mu.Unlock()
+}
+
+// FastModelStatuses reports the measured latency of every fast model used
+func (s *CompletionService) FastModelStatuses() []FastModelStatus {
+	return s.fast.statuses()
+}
+
+// expectsMultiLine guesses whether the user is about to write a block
+// rather than finish a line. Only these go to the standard model in auto
+// mode.
+func expectsMultiLine(req *CompletionRequest) bool {
+	if req.TriggerKind == TriggerInvoked {
+		return true
+	}
+	if midLine(req.Suffix) {
+		return false
+	}
+	lines := strings.Split(req.Prefix, "\n")
+	if strings.TrimSpace(lines[len(lines)-1]) != "" {
+		return false
+	}
+	for i := len(lines) - 2; i >= 0; i-- {
+		prev := strings.TrimSpace(lines[i])
+		if prev == "" {
+			continue
+		}
+		for _, opener := range []string{"{", ":", "(", "[", "=>", "->"} {
+			if strings.HasSuffix(prev, opener) {
+				return true
+			}
+		}
+		// A comment right above the cursor usually describes the code to write
+		return strings.HasPrefix(prev, "//") || strings.HasPrefix(prev, "#") || strings.HasSuffix(prev, "*/") || strings.HasSuffix(prev, `"""`)
+	}
+	return false
+}
+
+// fastRoute is a fast model chosen for one request
+type fastRoute struct {
+	choice ModelChoice
+	target time.Duration
+}
+
+// planFastRoute picks the fast model for a request, or nil when the
+// request should go straight to the standard model.
+func (s *CompletionService) planFastRoute(ctx context.Context, userID uint, req *CompletionRequest, available func(ModelChoice) bool) *fastRoute {
+	settings := defaultSettings(userID)
+	if userID > 0 && s.db != nil {
+		if loaded, err := s.GetSettings(ctx, userID); err == nil {
+			settings = loaded
+		}
+	}
+	switch settings.Mode {
+	case ModeQuality:
+		return nil
+	case ModeFast:
+	default:
+		if expectsMultiLine(req) {
+			return nil
+		}
+	}
+
+	if settings.FastProvider != "" {
+		choice := ModelChoice{Provider: settings.FastProvider, Model: settings.FastModel}
+		if available(choice) && s.fast.usable(choice) {
+			return &fastRoute{choice: choice, target: settings.latencyTarget()}
+		}
+		return nil
+	}
+	for _, choice := range s.fast.candidates() {
+		if available(choice) && s.fast.usable(choice) {
+			return &fastRoute{choice: choice, target: settings.latencyTarget()}
+		}
+	}
+	return nil
+}
+
+// generate runs the request on the fast model when one is planned and
+// escalates to the standard model if it errors, returns nothing or misses
+// its deadline. It returns the request that produced the response.
+func (s *CompletionService) generate(ctx context.Context, router *ai.AIRouter, aiReq *ai.AIRequest, route *fastRoute) (*ai.AIResponse, *ai.AIRequest, bool, error) {
+	if route != nil {
+		fastReq := *aiReq
+		fastReq.Provider = ai.AIProvider(route.choice.Provider)
+		fastReq.Model = route.choice.Model
+		fastReq.DisableFallback = true // escalation below replaces router fallback
+		if fastReq.MaxTokens > fastMaxTokens {
+			fastReq.MaxTokens = fastMaxTokens
+		}
+
+		fastCtx, cancel := context.WithTimeout(ctx, route.target*fastDeadlineFactor)
+		started := time.Now()
+		resp, err := router.Generate(fastCtx, &fastReq)
+		cancel()
+		if err == nil && resp != nil && strings.TrimSpace(resp.Content) == "" {
+			err = errors.New("empty completion")
+		}
+		s.fast.observe(route.choice, time.Since(started), err, route.target)
+		if err == nil {
+			s.metrics.RecordFastHit()
+			return resp, &fastReq, true, nil
+		}
+		if ctx.Err() != nil {
+			return nil, aiReq, false, ctx.Err()
+		}
+		s.metrics.RecordFastFallback()
+	}
+
+	resp, err := router.Generate(ctx, aiReq)
+	return resp, aiReq, false, err
+}
+
+// fastModels tracks fast model latency and health
+type fastModels struct {
+	mu     sync.Mutex
+	models []ModelChoice
+	health map[string]*fastHealth
+	now    func() time.Time
+}
+
+type fastHealth struct {
+	choice       ModelChoice
+	avgLatency   float64 // milliseconds, exponentially weighted
+	samples      int64
+	failures     int64
+	consecutive  int
+	skippedUntil time.Time
+}
+
+func newFastModels() *fastModels {
+	return &fastModels{models: DefaultFastModels, health: make(map[string]*fastHealth), now: time.Now}
+}
+
+func (f *fastModels) candidates() []ModelChoice {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ModelChoice(nil), f.models...)
+}
+
+// usable reports whether a model is not cooling down after being slow or
+// failing. A cooled-down model gets a fresh start.
+func (f *fastModels) usable(choice ModelChoice) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h, ok := f.health[choice.key()]
+	if !ok {
+		return true
+	}
+	now := f.now()
+	if now.Before(h.skippedUntil) {
+		return false
+	}
+	if !h.skippedUntil.IsZero() {
+		h.skippedUntil = time.Time{}
+		h.samples, h.avgLatency, h.consecutive = 0, 0, 0
+	}
+	return true
+}
+
+// observe records one fast attempt and starts a cooldown when the model
+// keeps failing or its average latency exceeds the target.
+func (f *fastModels) observe(choice ModelChoice, latency time.Duration, err error, target time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h, ok := f.health[choice.key()]
+	if !ok {
+		h = &fastHealth{choice: choice}
+		f.health[choice.key()] = h
+	}
+	if err != nil {
+		h.failures++
+		h.consecutive++
+		if h.consecutive >= fastMaxFailures {
+			h.skippedUntil = f.now().Add(fastCooldown)
+		}
+		return
+	}
+	h.consecutive = 0
+	ms := float64(latency.Milliseconds())
+	if h.samples == 0 {
+		h.avgLatency = ms
+	} else {
+		h.avgLatency = fastEWMAWeight*ms + (1-fastEWMAWeight)*h.avgLatency
+	}
+	h.samples++
+	if h.samples >= fastMinSamples && h.avgLatency > float64(target.Milliseconds()) {
+		h.skippedUntil = f.now().Add(fastCooldown)
+	}
+}
+
+func (f *fastModels) statuses() []FastModelStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []FastModelStatus
+	for _, h := range f.health {
+		status := FastModelStatus{
+			Provider:     h.choice.Provider,
+			Model:        h.choice.Model,
+			AvgLatencyMs: h.avgLatency,
+			Samples:      h.samples,
+			Failures:     h.failures,
+		}
+		if f.now().Before(h.skippedUntil) {
+			until := h.skippedUntil
+			status.SkippedUntil = &until
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Provider+"/"+out[i].Model < out[j].Provider+"/"+out[j].Model
+	})
+	return out
+}
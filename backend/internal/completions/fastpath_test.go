@@ -0,0 +1,148 @@
+package completions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExpectsMultiLine(t *testing.T) {
+	cases := []struct {
+		name string
+		req  CompletionRequest
+		want bool
+	}{
+		{"explicit invoke", CompletionRequest{Prefix: "x := ", TriggerKind: TriggerInvoked}, true},
+		{"finishing a line", CompletionRequest{Prefix: "func main() {\n\tfmt.Pri"}, false},
+		{"middle of a line", CompletionRequest{Prefix: "foo(", Suffix: ")\n"}, false},
+		{"after block opener", CompletionRequest{Prefix: "func main() {\n\t", Suffix: "\n}"}, true},
+		{"after python colon", CompletionRequest{Prefix: "def run(self):\n    "}, true},
+		{"after comment", CompletionRequest{Prefix: "// sort users by name\n"}, true},
+		{"after statement", CompletionRequest{Prefix: "x := 1\n"}, false},
+	}
+	for _, tc := range cases {
+		if got := expectsMultiLine(&tc.req); got != tc.want {
+			t.Errorf("%s: expectsMultiLine = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFastModelsCooldown(t *testing.T) {
+	f := newFastModels()
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	slow := ModelChoice{Provider: "ollama", Model: "tiny"}
+	flaky := ModelChoice{Provider: "gpt4", Model: "mini"}
+	target := 300 * time.Millisecond
+
+	for i := 0; i < fastMinSamples; i++ {
+		if !f.usable(slow) {
+			t.Fatalf("slow model skipped after %d samples", i)
+		}
+		f.observe(slow, 800*time.Millisecond, nil, target)
+	}
+	if f.usable(slow) {
+		t.Fatal("model averaging over the latency target should cool down")
+	}
+
+	for i := 0; i < fastMaxFailures; i++ {
+		f.observe(flaky, 0, errors.New("boom"), target)
+	}
+	if f.usable(flaky) {
+		t.Fatal("model failing repeatedly should cool down")
+	}
+	statuses := f.statuses()
+	if len(statuses) != 2 || statuses[0].Model != "mini" || statuses[0].Failures != 3 || statuses[0].SkippedUntil == nil {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+
+	// After the cooldown the model starts over
+	now = now.Add(fastCooldown + time.Second)
+	if !f.usable(slow) || !f.usable(flaky) {
+		t.Fatal("models should be retried after the cooldown")
+	}
+	f.observe(slow, 100*time.Millisecond, nil, target)
+	if !f.usable(slow) {
+		t.Fatal("one fast sample after cooldown should keep the model usable")
+	}
+}
+
+func TestPlanFastRouteHonorsSettings(t *testing.T) {
+	svc := newTelemetryTestService(t)
+	if err := svc.db.AutoMigrate(&CompletionSettings{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc.fast = newFastModels()
+	ctx := context.Background()
+	noOllama := func(c ModelChoice) bool { return c.Provider != "ollama" }
+	short := &CompletionRequest{Prefix: "fmt.Pri"}
+	block := &CompletionRequest{Prefix: "if err != nil {\n\t"}
+
+	// Auto mode: short completions go to the first available fast model
+	route := svc.planFastRoute(ctx, 1, short, noOllama)
+	if route == nil || route.choice.Provider != "gpt4" || route.target != DefaultLatencyTarget {
+		t.Fatalf("auto short route = %+v", route)
+	}
+	if route := svc.planFastRoute(ctx, 1, block, noOllama); route != nil {
+		t.Fatalf("auto block route = %+v, want standard model", route)
+	}
+
+	mode := ModeFast
+	provider, model, target := "ollama", "qwen2.5-coder:0.5b", 200
+	if _, err := svc.UpdateSettings(ctx, 1, CompletionSettingsUpdate{Mode: &mode, FastProvider: &provider, FastModel: &model, LatencyTargetMs: &target}); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+	all := func(ModelChoice) bool { return true }
+	route = svc.planFastRoute(ctx, 1, block, all)
+	if route == nil || route.choice.Model != model || route.target != 200*time.Millisecond {
+		t.Fatalf("fast mode route = %+v", route)
+	}
+	// A chosen fast model that is unavailable means the standard model
+	if route := svc.planFastRoute(ctx, 1, short, noOllama); route != nil {
+		t.Fatalf("unavailable user model route = %+v", route)
+	}
+
+	mode = ModeQuality
+	if _, err := svc.UpdateSettings(ctx, 1, CompletionSettingsUpdate{Mode: &mode}); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+	if route := svc.planFastRoute(ctx, 1, short, all); route != nil {
+		t.Fatalf("quality mode route = %+v", route)
+	}
+
+	// Settings survive a cold cache
+	svc.settings.Delete(uint(1))
+	settings, err := svc.GetSettings(ctx, 1)
+	if err != nil || settings.Mode != ModeQuality || settings.FastModel != model || settings.LatencyTargetMs != 200 {
+		t.Fatalf("reloaded settings = %+v, %v", settings, err)
+	}
+}
+
+func TestUpdateSettingsValidation(t *testing.T) {
+	svc := newTelemetryTestService(t)
+	if err := svc.db.AutoMigrate(&CompletionSettings{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+
+	badMode := CompletionMode("turbo")
+	if _, err := svc.UpdateSettings(ctx, 1, CompletionSettingsUpdate{Mode: &badMode}); !errors.Is(err, ErrInvalidMode) {
+		t.Fatalf("mode err = %v", err)
+	}
+	target := 50
+	if _, err := svc.UpdateSettings(ctx, 1, CompletionSettingsUpdate{LatencyTargetMs: &target}); !errors.Is(err, ErrInvalidLatencyTarget) {
+		t.Fatalf("target err = %v", err)
+	}
+	model := "mini"
+	if _, err := svc.UpdateSettings(ctx, 1, CompletionSettingsUpdate{FastModel: &model}); !errors.Is(err, ErrInvalidFastModel) {
+		t.Fatalf("model without provider err = %v", err)
+	}
+	provider := "nope"
+	if _, err := svc.UpdateSettings(ctx, 1, CompletionSettingsUpdate{FastProvider: &provider, FastModel: &model}); !errors.Is(err, ErrInvalidFastModel) {
+		t.Fatalf("unknown provider err = %v", err)
+	}
+	if settings, err := svc.GetSettings(ctx, 1); err != nil || settings.Mode != ModeAuto || settings.FastModel != "" {
+		t.Fatalf("failed updates changed settings: %+v, %v", settings, err)
+	}
+}
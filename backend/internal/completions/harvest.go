@@ -0,0 +1,287 @@
+package completions
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/internal/symbols"
+	"apex-build/pkg/models"
+)
+
+// ProjectAccess decides whether a user may read a project. Implemented by
+// ownership.Service.
+type ProjectAccess interface {
+	CanAccess(userID uint, project *models.Project, action string) bool
+}
+
+// SymbolDefinitions looks up declarations by exact name. Implemented by
+// symbols.Service.
+type SymbolDefinitions interface {
+	Definitions(ctx context.Context, projectID uint, names []string, limit int) ([]symbols.Symbol, error)
+}
+
+const (
+	// harvestTimeout bounds the database work done per completion; a slow
+	// lookup drops the extra context rather than delaying the suggestion.
+	harvestTimeout = 150 * time.Millisecond
+
+	harvestScanLines      = 60 // prefix lines scanned for identifiers
+	maxHarvestIdentifiers = 24
+	maxHarvestDefinitions = 8
+	maxHarvestSiblings    = 2
+	maxSiblingCandidates  = 20
+	maxSiblingSnippet     = 1200
+	maxHarvestImports     = 20
+)
+
+var (
+	identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	goImportPattern   = regexp.MustCompile(`^\s*(?:[A-Za-z_.]\w*\s+)?"([^"]+)"\s*$`)
+	jsImportPattern   = regexp.MustCompile(`(?:from\s+|require\(\s*|import\s+)['"]([^'"]+)['"]`)
+	pyImportPattern   = regexp.MustCompile(`^\s*(?:from\s+([\w.]+)\s+import|import\s+([\w.]+))`)
+	useImportPattern  = regexp.MustCompile(`^\s*(?:pub\s+)?(?:use|import)\s+([\w.:*]+)`)
+	includePattern    = regexp.MustCompile(`^\s*#\s*include\s+[<"]([^>"]+)[>"]`)
+)
+
+// harvestKeywords are skipped when collecting identifiers; they never have
+// project definitions.
+var harvestKeywords = map[string]bool{
+	"if": true, "else": true, "for": true, "while": true, "return": true, "func": true,
+	"function": true, "def": true, "class": true, "const": true, "let": true, "var": true,
+	"type": true, "struct": true, "interface": true, "import": true, "from": true,
+	"package": true, "new": true, "this": true, "self": true, "true": true, "false": true,
+	"nil": true, "null": true, "None": true, "True": true, "False": true, "async": true,
+	"await": true, "export": true, "default": true, "public": true, "private": true,
+	"static": true, "switch": true, "case": true, "break": true, "continue": true,
+	"range": true, "map": true, "string": true, "int": true, "bool": true, "error": true,
+	"fn": true, "impl": true, "pub": true, "use": true, "mut": true, "in": true, "of": true,
+	"and": true, "or": true, "not": true, "try": true, "catch": true, "except": true,
+}
+
+// harvestContext fills in context the editor did not send: the file's
+// imports, definitions of identifiers near the cursor, and snippets from
+// sibling files. Anything the client supplied is kept as is.
+func (s *CompletionService) harvestContext(ctx context.Context, userID uint, req *CompletionRequest) {
+	if len(req.Context.FileImports) == 0 {
+		req.Context.FileImports = extractImports(req.Language, req.Prefix)
+	}
+	if s.db == nil || req.ProjectID == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, harvestTimeout)
+	defer cancel()
+
+	var project models.Project
+	if err := s.db.WithContext(ctx).Select("id", "owner_id", "organization_id").First(&project, req.ProjectID).Error; err != nil {
+		return
+	}
+	if !s.canReadProject(userID, &project) {
+		return
+	}
+
+	if len(req.Context.Definitions) == 0 && s.symbols != nil {
+		req.Context.Definitions = s.harvestDefinitions(ctx, req)
+	}
+	if len(req.Context.RelatedFiles) == 0 {
+		req.Context.RelatedFiles = s.harvestSiblings(ctx, req)
+	}
+}
+
+func (s *CompletionService) canReadProject(userID uint, project *models.Project) bool {
+	if s.access == nil {
+		return project.OwnerID == userID
+	}
+	return s.access.CanAccess(userID, project, "read")
+}
+
+func (s *CompletionService) harvestDefinitions(ctx context.Context, req *CompletionRequest) []SymbolDefinition {
+	names := identifiersNearCursor(req.Prefix)
+	if len(names) == 0 {
+		return nil
+	}
+	found, err := s.symbols.Definitions(ctx, req.ProjectID, names, maxHarvestDefinitions*3)
+	if err != nil {
+		return nil
+	}
+
+	// Prefer identifiers closest to the cursor, and skip declarations in the
+	// file being edited; the model already sees those.
+	rank := make(map[string]int, len(names))
+	for i, name := range names {
+		rank[name] = i
+	}
+	sort.SliceStable(found, func(i, j int) bool { return rank[found[i].Name] < rank[found[j].Name] })
+
+	seen := map[string]bool{}
+	var defs []SymbolDefinition
+	for _, sym := range found {
+		if sym.FilePath == req.FilePath || seen[sym.Name] {
+			continue
+		}
+		seen[sym.Name] = true
+		defs = append(defs, SymbolDefinition{
+			Name:      sym.Name,
+			Kind:      sym.Kind,
+			Signature: sym.Signature,
+			Path:      sym.FilePath,
+			Line:      sym.Line,
+		})
+		if len(defs) == maxHarvestDefinitions {
+			break
+		}
+	}
+	return defs
+}
+
+// harvestSiblings returns the heads of files in the editor file's directory,
+// those named by the file's imports first.
+func (s *CompletionService) harvestSiblings(ctx context.Context, req *CompletionRequest) []RelatedFile {
+	if req.FilePath == "" {
+		return nil
+	}
+	dir := path.Dir(req.FilePath)
+	query := s.db.WithContext(ctx).Model(&models.File{}).
+		Select("path", "content").
+		Where("project_id = ? AND type = ? AND is_binary = ? AND path <> ?", req.ProjectID, "file", false, req.FilePath)
+	if dir == "." || dir == "/" {
+		query = query.Where("path NOT LIKE ?", "%/%")
+	} else {
+		query = query.Where("path LIKE ? AND path NOT LIKE ?", dir+"/%", dir+"/%/%")
+	}
+
+	var files []models.File
+	if err := query.Order("updated_at DESC").Limit(maxSiblingCandidates).Find(&files).Error; err != nil {
+		return nil
+	}
+
+	ext := path.Ext(req.FilePath)
+	score := func(f models.File) int {
+		base := strings.TrimSuffix(path.Base(f.Path), path.Ext(f.Path))
+		switch {
+		case importsMention(req.Context.FileImports, base):
+			return 0
+		case path.Ext(f.Path) == ext:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return score(files[i]) < score(files[j]) })
+
+	var related []RelatedFile
+	for _, f := range files {
+		if score(f) == 2 || strings.TrimSpace(f.Content) == "" {
+			continue
+		}
+		related = append(related, RelatedFile{
+			Path:     f.Path,
+			Language: req.Language,
+			Snippet:  headSnippet(f.Content, maxSiblingSnippet),
+		})
+		if len(related) == maxHarvestSiblings {
+			break
+		}
+	}
+	return related
+}
+
+func importsMention(imports []string, base string) bool {
+	if base == "" {
+		return false
+	}
+	for _, imp := range imports {
+		if strings.HasSuffix(imp, "/"+base) || strings.HasSuffix(imp, "."+base) || imp == base {
+			return true
+		}
+	}
+	return false
+}
+
+// headSnippet returns the start of content cut at a line boundary.
+func headSnippet(content string, limit int) string {
+	if len(content) <= limit {
+		return strings.TrimRight(content, "\n")
+	}
+	cut := content[:limit]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut
+}
+
+// extractImports lists the modules a file imports, read from the code
+// before the cursor, where imports normally live.
+func extractImports(language, source string) []string {
+	var imports []string
+	seen := map[string]bool{}
+	add := func(imp string) {
+		if imp != "" && !seen[imp] && len(imports) < maxHarvestImports {
+			seen[imp] = true
+			imports = append(imports, imp)
+		}
+	}
+
+	inGoBlock := false
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch language {
+		case "go":
+			switch {
+			case strings.HasPrefix(trimmed, "import ("):
+				inGoBlock = true
+			case inGoBlock && trimmed == ")":
+				inGoBlock = false
+			case inGoBlock || strings.HasPrefix(trimmed, "import "):
+				if m := goImportPattern.FindStringSubmatch(strings.TrimPrefix(trimmed, "import ")); m != nil {
+					add(m[1])
+				}
+			}
+		case "javascript", "typescript", "javascriptreact", "typescriptreact", "jsx", "tsx":
+			for _, m := range jsImportPattern.FindAllStringSubmatch(line, -1) {
+				add(m[1])
+			}
+		case "python":
+			if m := pyImportPattern.FindStringSubmatch(line); m != nil {
+				add(m[1] + m[2])
+			}
+		case "c", "cpp", "c++", "objective-c":
+			if m := includePattern.FindStringSubmatch(line); m != nil {
+				add(m[1])
+			}
+		default:
+			if m := useImportPattern.FindStringSubmatch(line); m != nil {
+				add(strings.TrimSuffix(m[1], ";"))
+			}
+		}
+	}
+	return imports
+}
+
+// identifiersNearCursor returns the distinct identifiers in the last lines
+// of the prefix, nearest the cursor first.
+func identifiersNearCursor(prefix string) []string {
+	lines := strings.Split(prefix, "\n")
+	if len(lines) > harvestScanLines {
+		lines = lines[len(lines)-harvestScanLines:]
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for i := len(lines) - 1; i >= 0 && len(names) < maxHarvestIdentifiers; i-- {
+		matches := identifierPattern.FindAllString(lines[i], -1)
+		for j := len(matches) - 1; j >= 0 && len(names) < maxHarvestIdentifiers; j-- {
+			name := matches[j]
+			if len(name) < 3 || harvestKeywords[name] || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
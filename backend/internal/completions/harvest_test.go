@@ -0,0 +1,136 @@
+package completions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"apex-build/internal/symbols"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newHarvestTestService(t *testing.T) (*CompletionService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := symbols.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate symbols: %v", err)
+	}
+	svc := &CompletionService{db: db}
+	svc.SetContextSources(nil, symbols.NewService(db))
+	return svc, db
+}
+
+func TestHarvestContextAddsDefinitionsAndSiblings(t *testing.T) {
+	svc, db := newHarvestTestService(t)
+	ctx := context.Background()
+
+	project := models.Project{Name: "app", OwnerID: 7}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	files := []models.File{
+		{ProjectID: project.ID, Path: "api/user.go", Name: "user.go", Type: "file", Content: "package api\n\ntype User struct {\n\tName string\n}\n\nfunc LoadUser(id int) (*User, error) {\n\treturn nil, nil\n}\n"},
+		{ProjectID: project.ID, Path: "api/handler.go", Name: "handler.go", Type: "file", Content: "package api\n"},
+		{ProjectID: project.ID, Path: "api/README.md", Name: "README.md", Type: "file", Content: "# api\n"},
+		{ProjectID: project.ID, Path: "api/nested/deep.go", Name: "deep.go", Type: "file", Content: "package nested\n"},
+	}
+	for i := range files {
+		if err := db.Create(&files[i]).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+	if _, err := symbols.NewService(db).Refresh(ctx); err != nil {
+		t.Fatalf("index symbols: %v", err)
+	}
+
+	req := &CompletionRequest{
+		ProjectID: project.ID,
+		FilePath:  "api/handler.go",
+		Language:  "go",
+		Prefix:    "package api\n\nimport (\n\t\"fmt\"\n\tlog \"log/slog\"\n)\n\nfunc handle(id int) {\n\tu, err := LoadUser(id)\n\t",
+	}
+	svc.harvestContext(ctx, 7, req)
+
+	if strings.Join(req.Context.FileImports, ",") != "fmt,log/slog" {
+		t.Fatalf("imports = %v", req.Context.FileImports)
+	}
+	if len(req.Context.Definitions) != 1 || req.Context.Definitions[0].Name != "LoadUser" || req.Context.Definitions[0].Path != "api/user.go" {
+		t.Fatalf("definitions = %+v", req.Context.Definitions)
+	}
+	if len(req.Context.RelatedFiles) != 1 || req.Context.RelatedFiles[0].Path != "api/user.go" {
+		t.Fatalf("related files = %+v, want only the sibling .go file", req.Context.RelatedFiles)
+	}
+
+	prefix := svc.buildFIMPrefix(req)
+	if !strings.HasPrefix(prefix, "// Path: api/handler.go\n") || !strings.Contains(prefix, "// Compare this snippet from api/user.go:") {
+		t.Fatalf("FIM prefix missing harvested context:\n%s", prefix)
+	}
+	if !strings.HasSuffix(prefix, req.Prefix) {
+		t.Fatal("FIM prefix should end with the code before the cursor")
+	}
+
+	// Another user's request must not see the project's files
+	other := &CompletionRequest{ProjectID: project.ID, FilePath: "api/handler.go", Language: "go", Prefix: req.Prefix}
+	svc.harvestContext(ctx, 8, other)
+	if len(other.Context.Definitions) != 0 || len(other.Context.RelatedFiles) != 0 {
+		t.Fatalf("harvested context for a user without access: %+v", other.Context)
+	}
+}
+
+func TestExtractImports(t *testing.T) {
+	cases := []struct {
+		language, source, want string
+	}{
+		{"typescript", "import React from 'react'\nimport { api } from \"./api\"\nconst x = require('lodash')\n", "react,./api,lodash"},
+		{"python", "import os\nfrom app.models import User\n", "os,app.models"},
+		{"rust", "use std::io;\npub use crate::db::Pool;\n", "std::io,crate::db::Pool"},
+		{"go", "import \"strings\"\n", "strings"},
+	}
+	for _, tc := range cases {
+		if got := strings.Join(extractImports(tc.language, tc.source), ","); got != tc.want {
+			t.Errorf("%s imports = %q, want %q", tc.language, got, tc.want)
+		}
+	}
+}
+
+func TestStopTokensAndFIMParsing(t *testing.T) {
+	svc := &CompletionService{}
+
+	endOfLine := &CompletionRequest{Language: "go", Suffix: "\n}\n"}
+	stops := svc.getStopTokens(endOfLine)
+	if stops[0] != "\nfunc " || stops[len(stops)-1] != "```" {
+		t.Fatalf("end-of-line stops = %q", stops)
+	}
+
+	midLine := &CompletionRequest{Language: "tsx", Suffix: " + total)\n"}
+	if stops := svc.getStopTokens(midLine); stops[0] != "\n" || stops[1] != "\nfunction " {
+		t.Fatalf("mid-line stops = %q", stops)
+	}
+	if midLineRequest := (&CompletionRequest{Suffix: ")}\n"}); svc.getStopTokens(midLineRequest)[0] == "\n" {
+		t.Fatal("auto-inserted closers should not count as code after the cursor")
+	}
+
+	items := svc.parseCompletions(" a + b\n}\n\nfunc next() {}", endOfLine, true)
+	if len(items) == 0 || items[0].Text != " a + b\n}" {
+		t.Fatalf("FIM completion = %+v", items)
+	}
+
+	custom := &CompletionRequest{Language: "go", StopTokens: []string{";"}}
+	if stops := svc.getStopTokens(custom); len(stops) != 1 || stops[0] != ";" {
+		t.Fatalf("client stop tokens should win, got %q", stops)
+	}
+}
@@ -0,0 +1,242 @@
+package completions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CompletionOutcome is what the user did with a shown completion
+type CompletionOutcome string
+
+const (
+	OutcomeAccepted CompletionOutcome = "accepted" // inserted in full
+	OutcomePartial  CompletionOutcome = "partial"  // accepted word- or line-wise
+	OutcomeRejected CompletionOutcome = "rejected" // dismissed or typed over
+)
+
+const (
+	// issuedTTL is how long after serving a completion the editor may
+	// report what happened to it
+	issuedTTL = 15 * time.Minute
+
+	// DefaultAnalyticsDays is the window of the acceptance analytics
+	DefaultAnalyticsDays = 30
+	// MaxAnalyticsDays bounds a single analytics query
+	MaxAnalyticsDays = 365
+)
+
+var (
+	// ErrUnknownCompletion is returned for events about completions this
+	// server did not serve recently
+	ErrUnknownCompletion = errors.New("unknown or expired completion")
+	// ErrInvalidOutcome is returned for outcomes other than accepted,
+	// partial and rejected
+	ErrInvalidOutcome = errors.New("outcome must be accepted, partial or rejected")
+)
+
+// CompletionEvent records the outcome of one shown completion. Credit is 1
+// for accepted, the accepted share of the text for partial and 0 for
+// rejected; its average is the acceptance rate.
+type CompletionEvent struct {
+	ID            uint              `gorm:"primarykey" json:"id"`
+	UserID        uint              `gorm:"not null;uniqueIndex:idx_completion_events_user_completion;index:idx_completion_events_user_created" json:"user_id"`
+	CompletionID  string            `gorm:"type:varchar(64);not null;uniqueIndex:idx_completion_events_user_completion" json:"completion_id"`
+	ProjectID     *uint             `json:"project_id,omitempty"`
+	Language      string            `gorm:"type:varchar(50);index:idx_completion_events_language_created" json:"language"`
+	Provider      string            `gorm:"type:varchar(50)" json:"provider"`
+	Model         string            `gorm:"type:varchar(100)" json:"model"`
+	Outcome       CompletionOutcome `gorm:"type:varchar(20);not null" json:"outcome"`
+	ShownChars    int               `json:"shown_chars"`
+	AcceptedChars int               `json:"accepted_chars"`
+	Credit        float64           `json:"credit"`
+	CreatedAt     time.Time         `gorm:"index:idx_completion_events_user_created;index:idx_completion_events_language_created" json:"created_at"`
+}
+
+// CompletionEventInput is an editor's report about a shown completion
+type CompletionEventInput struct {
+	CompletionID  string            `json:"completion_id" binding:"required"`
+	Outcome       CompletionOutcome `json:"outcome" binding:"required"`
+	AcceptedChars int               `json:"accepted_chars,omitempty"` // for partial accepts
+}
+
+// ModelAcceptance aggregates completion outcomes for one model and language
+type ModelAcceptance struct {
+	Language       string  `json:"language"`
+	Provider       string  `json:"provider"`
+	Model          string  `json:"model"`
+	Shown          int64   `json:"shown"`
+	Accepted       int64   `json:"accepted"`
+	Partial        int64   `json:"partial"`
+	Rejected       int64   `json:"rejected"`
+	AcceptedChars  int64   `json:"accepted_chars"`
+	AcceptanceRate float64 `json:"acceptance_rate"` // 0-1
+}
+
+// AcceptanceAnalytics is a user's completion acceptance over a window
+type AcceptanceAnalytics struct {
+	From      time.Time               `json:"from"`
+	To        time.Time               `json:"to"`
+	Rows      []ModelAcceptance       `json:"rows"`
+	Totals    ModelAcceptance         `json:"totals"`
+	Preferred map[string]*ModelChoice `json:"preferred,omitempty"` // language -> model the tuner picks
+}
+
+// issuedCompletion remembers which model produced a served completion
+type issuedCompletion struct {
+	projectID uint
+	language  string
+	provider  string
+	model     string
+	chars     int
+	expiresAt time.Time
+}
+
+// issuedCompletions maps served completion IDs to their origin until the
+// editor reports on them
+type issuedCompletions struct {
+	mu    sync.Mutex
+	items map[string]issuedCompletion
+}
+
+func newIssuedCompletions() *issuedCompletions {
+	return &issuedCompletions{items: make(map[string]issuedCompletion)}
+}
+
+func (c *issuedCompletions) remember(req *CompletionRequest, resp *CompletionResponse) {
+	expires := time.Now().Add(issuedTTL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, item := range resp.Completions {
+		c.items[item.ID] = issuedCompletion{
+			projectID: req.ProjectID,
+			language:  req.Language,
+			provider:  resp.Provider,
+			model:     resp.Model,
+			chars:     len(item.InsertText),
+			expiresAt: expires,
+		}
+	}
+}
+
+func (c *issuedCompletions) lookup(id string) (issuedCompletion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[id]
+	if !ok || time.Now().After(item.expiresAt) {
+		return issuedCompletion{}, false
+	}
+	return item, true
+}
+
+func (c *issuedCompletions) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, item := range c.items {
+		if now.After(item.expiresAt) {
+			delete(c.items, id)
+		}
+	}
+}
+
+// RecordEvent stores what the user did with a completion. Repeated reports
+// for the same completion keep the first.
+func (s *CompletionService) RecordEvent(ctx context.Context, userID uint, input CompletionEventInput) error {
+	issued, ok := s.issued.lookup(input.CompletionID)
+	if !ok {
+		return ErrUnknownCompletion
+	}
+
+	event := CompletionEvent{
+		UserID:       userID,
+		CompletionID: input.CompletionID,
+		Language:     issued.language,
+		Provider:     issued.provider,
+		Model:        issued.model,
+		Outcome:      input.Outcome,
+		ShownChars:   issued.chars,
+	}
+	if issued.projectID != 0 {
+		projectID := issued.projectID
+		event.ProjectID = &projectID
+	}
+
+	switch input.Outcome {
+	case OutcomeAccepted:
+		event.AcceptedChars = issued.chars
+		event.Credit = 1
+	case OutcomePartial:
+		event.AcceptedChars = min(max(input.AcceptedChars, 0), issued.chars)
+		if issued.chars > 0 {
+			event.Credit = float64(event.AcceptedChars) / float64(issued.chars)
+		}
+	case OutcomeRejected:
+	default:
+		return ErrInvalidOutcome
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&event).Error
+	if err != nil {
+		return fmt.Errorf("completions: record event: %w", err)
+	}
+	return nil
+}
+
+// acceptanceSelect aggregates completion_events by language and model
+const acceptanceSelect = `language, provider, model,
+	COUNT(*) AS shown,
+	SUM(CASE WHEN outcome = 'accepted' THEN 1 ELSE 0 END) AS accepted,
+	SUM(CASE WHEN outcome = 'partial' THEN 1 ELSE 0 END) AS partial,
+	SUM(CASE WHEN outcome = 'rejected' THEN 1 ELSE 0 END) AS rejected,
+	COALESCE(SUM(accepted_chars), 0) AS accepted_chars,
+	COALESCE(AVG(credit), 0) AS acceptance_rate`
+
+func acceptanceRows(db *gorm.DB) ([]ModelAcceptance, error) {
+	var rows []ModelAcceptance
+	err := db.Model(&CompletionEvent{}).
+		Select(acceptanceSelect).
+		Group("language, provider, model").
+		Order("language, shown DESC, provider, model").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetAcceptanceAnalytics returns the user's acceptance rates per model and
+// language over the last days, and the model the tuner currently prefers
+// for each of those languages.
+func (s *CompletionService) GetAcceptanceAnalytics(ctx context.Context, userID uint, days int) (*AcceptanceAnalytics, error) {
+	if days <= 0 {
+		days = DefaultAnalyticsDays
+	}
+	days = min(days, MaxAnalyticsDays)
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -days)
+
+	rows, err := acceptanceRows(s.db.WithContext(ctx).Where("user_id = ? AND created_at >= ?", userID, from))
+	if err != nil {
+		return nil, fmt.Errorf("completions: acceptance analytics: %w", err)
+	}
+
+	result := &AcceptanceAnalytics{From: from, To: to, Rows: rows, Preferred: map[string]*ModelChoice{}}
+	var credit float64
+	for _, row := range rows {
+		result.Totals.Shown += row.Shown
+		result.Totals.Accepted += row.Accepted
+		result.Totals.Partial += row.Partial
+		result.Totals.Rejected += row.Rejected
+		result.Totals.AcceptedChars += row.AcceptedChars
+		credit += row.AcceptanceRate * float64(row.Shown)
+		if _, seen := result.Preferred[row.Language]; !seen {
+			result.Preferred[row.Language] = s.tuner.preferred(ctx, row.Language)
+		}
+	}
+	if result.Totals.Shown > 0 {
+		result.Totals.AcceptanceRate = credit / float64(result.Totals.Shown)
+	}
+	return result, nil
+}
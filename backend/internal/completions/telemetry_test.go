@@ -0,0 +1,147 @@
+package completions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTelemetryTestService(t *testing.T) *CompletionService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&CompletionEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := &CompletionService{db: db, issued: newIssuedCompletions()}
+	svc.tuner = newModelTuner(svc)
+	return svc
+}
+
+// serve pretends provider/model answered a request with one completion
+func serve(svc *CompletionService, id, language, provider, model, text string) {
+	svc.issued.remember(&CompletionRequest{Language: language, ProjectID: 3}, &CompletionResponse{
+		Provider:    provider,
+		Model:       model,
+		Completions: []CompletionItem{{ID: id, InsertText: text}},
+	})
+}
+
+func TestRecordEventAndAnalytics(t *testing.T) {
+	svc := newTelemetryTestService(t)
+	ctx := context.Background()
+
+	serve(svc, "a", "go", "openrouter", "coder-a", "return nil")
+	serve(svc, "b", "go", "openrouter", "coder-a", "0123456789")
+	serve(svc, "c", "go", "openrouter", "coder-a", "x")
+	serve(svc, "d", "python", "ollama", "coder-b", "pass")
+
+	events := []CompletionEventInput{
+		{CompletionID: "a", Outcome: OutcomeAccepted},
+		{CompletionID: "b", Outcome: OutcomePartial, AcceptedChars: 5},
+		{CompletionID: "c", Outcome: OutcomeRejected},
+		{CompletionID: "d", Outcome: OutcomeAccepted},
+		{CompletionID: "a", Outcome: OutcomeRejected}, // duplicate report keeps the first
+	}
+	for _, event := range events {
+		if err := svc.RecordEvent(ctx, 1, event); err != nil {
+			t.Fatalf("record %+v: %v", event, err)
+		}
+	}
+	if err := svc.RecordEvent(ctx, 1, CompletionEventInput{CompletionID: "zzz", Outcome: OutcomeAccepted}); !errors.Is(err, ErrUnknownCompletion) {
+		t.Fatalf("unknown completion err = %v", err)
+	}
+	if err := svc.RecordEvent(ctx, 1, CompletionEventInput{CompletionID: "a", Outcome: "maybe"}); !errors.Is(err, ErrInvalidOutcome) {
+		t.Fatalf("invalid outcome err = %v", err)
+	}
+	// Another user's events stay out of the analytics
+	if err := svc.RecordEvent(ctx, 2, CompletionEventInput{CompletionID: "a", Outcome: OutcomeRejected}); err != nil {
+		t.Fatalf("record for user 2: %v", err)
+	}
+
+	analytics, err := svc.GetAcceptanceAnalytics(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("analytics: %v", err)
+	}
+	if len(analytics.Rows) != 2 || analytics.Totals.Shown != 4 {
+		t.Fatalf("analytics = %+v", analytics)
+	}
+	goRow := analytics.Rows[0]
+	if goRow.Language != "go" || goRow.Model != "coder-a" || goRow.Shown != 3 || goRow.Accepted != 1 || goRow.Partial != 1 || goRow.Rejected != 1 {
+		t.Fatalf("go row = %+v", goRow)
+	}
+	if rate := goRow.AcceptanceRate; rate < 0.49 || rate > 0.51 {
+		t.Fatalf("go acceptance rate = %v, want 0.5 (1 + 0.5 + 0 over 3)", rate)
+	}
+	if goRow.AcceptedChars != 15 {
+		t.Fatalf("go accepted chars = %d, want 15", goRow.AcceptedChars)
+	}
+}
+
+func TestTunerPrefersBestModelAndExplores(t *testing.T) {
+	svc := newTelemetryTestService(t)
+	ctx := context.Background()
+	all := func(ModelChoice) bool { return true }
+
+	svc.SetModelCandidates(ParseModelCandidates("openrouter:coder-a, ollama:qwen2.5-coder:7b, bad"))
+	if got := svc.tuner.candidates; len(got) != 2 || got[1].Model != "qwen2.5-coder:7b" {
+		t.Fatalf("candidates = %+v", got)
+	}
+
+	// Without enough outcomes nothing is trusted yet
+	svc.tuner.random = func() float64 { return 0.5 }
+	if choice := svc.tuner.choose(ctx, "go", all); choice != nil {
+		t.Fatalf("choice without data = %+v", choice)
+	}
+
+	n := 0
+	record := func(provider, model string, accepted bool) {
+		n++
+		id := fmt.Sprintf("c%d", n)
+		serve(svc, id, "go", provider, model, "code")
+		outcome := OutcomeRejected
+		if accepted {
+			outcome = OutcomeAccepted
+		}
+		if err := svc.RecordEvent(ctx, uint(n%5+1), CompletionEventInput{CompletionID: id, Outcome: outcome}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	for i := 0; i < tunerMinSamples; i++ {
+		record("openrouter", "coder-a", i%4 == 0)      // 25%
+		record("ollama", "qwen2.5-coder:7b", i%4 != 0) // 75%
+	}
+	svc.tuner.loadedAt = svc.tuner.loadedAt.AddDate(0, 0, -1)
+
+	choice := svc.tuner.choose(ctx, "go", all)
+	if choice == nil || choice.Provider != "ollama" {
+		t.Fatalf("choice = %+v, want the ollama model", choice)
+	}
+	if choice := svc.tuner.choose(ctx, "python", all); choice != nil {
+		t.Fatalf("python has no outcomes, choice = %+v", choice)
+	}
+
+	// Unavailable providers are never picked
+	noOllama := func(c ModelChoice) bool { return c.Provider != "ollama" }
+	if choice := svc.tuner.choose(ctx, "go", noOllama); choice != nil {
+		t.Fatalf("choice with one available model = %+v, want default routing", choice)
+	}
+
+	// Exploration sends a share of requests to the least-sampled model
+	svc.SetModelCandidates(append(svc.tuner.candidates, ModelChoice{Provider: "openai", Model: "new-model"}))
+	svc.tuner.random = func() float64 { return 0 }
+	if choice := svc.tuner.choose(ctx, "go", all); choice == nil || choice.Model != "new-model" {
+		t.Fatalf("explore choice = %+v, want new-model", choice)
+	}
+}
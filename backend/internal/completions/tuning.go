@@ -0,0 +1,169 @@
+package completions
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tunerWindow is how far back acceptance events count toward model choice
+	tunerWindow = 30 * 24 * time.Hour
+	// tunerRefresh is how often aggregated acceptance rates are reloaded
+	tunerRefresh = 5 * time.Minute
+	// tunerMinSamples is how many outcomes a model needs before its
+	// acceptance rate is trusted
+	tunerMinSamples = 30
+	// tunerExploreRate is the share of requests routed to a model other
+	// than the current best, so every candidate keeps collecting outcomes
+	tunerExploreRate = 0.1
+)
+
+// ModelChoice is a provider and model completions can be routed to
+type ModelChoice struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+func (c ModelChoice) key() string { return c.Provider + "/" + c.Model }
+
+// ParseModelCandidates parses "provider:model" pairs separated by commas,
+// e.g. "openrouter:qwen/qwen-2.5-coder-32b-instruct,ollama:qwen2.5-coder:7b".
+// The model is everything after the first colon.
+func ParseModelCandidates(raw string) []ModelChoice {
+	var choices []ModelChoice
+	for _, part := range strings.Split(raw, ",") {
+		provider, model, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || provider == "" || model == "" {
+			continue
+		}
+		choices = append(choices, ModelChoice{Provider: provider, Model: model})
+	}
+	return choices
+}
+
+// modelTuner prefers the model with the best acceptance rate per language
+// across all users, explores the other candidates on a small share of
+// requests, and leaves routing alone until there is a choice to make.
+type modelTuner struct {
+	service *CompletionService
+
+	mu         sync.Mutex
+	candidates []ModelChoice
+	stats      map[string][]ModelAcceptance // by language
+	loadedAt   time.Time
+	random     func() float64
+}
+
+func newModelTuner(service *CompletionService) *modelTuner {
+	return &modelTuner{service: service, random: rand.Float64}
+}
+
+// SetModelCandidates sets the models auto-tuning explores for platform
+// (non-BYOK) completions. Models seen in past outcomes are considered too.
+func (s *CompletionService) SetModelCandidates(candidates []ModelChoice) {
+	s.tuner.mu.Lock()
+	s.tuner.candidates = candidates
+	s.tuner.mu.Unlock()
+}
+
+// choose returns the model to route a completion to, or nil to use the
+// router's default. available filters out providers the router lacks.
+func (t *modelTuner) choose(ctx context.Context, language string, available func(ModelChoice) bool) *ModelChoice {
+	pool, stats := t.pool(ctx, language, available)
+	if len(pool) < 2 {
+		return nil
+	}
+
+	if t.random() < tunerExploreRate {
+		// Gather outcomes for the least-sampled model first
+		var pick *ModelChoice
+		var fewest int64 = -1
+		for i := range pool {
+			shown := stats[pool[i].key()].Shown
+			if fewest < 0 || shown < fewest {
+				pick, fewest = &pool[i], shown
+			}
+		}
+		return pick
+	}
+	return best(pool, stats)
+}
+
+// preferred returns the model the tuner picks for language when not
+// exploring, or nil when there is nothing to choose between.
+func (t *modelTuner) preferred(ctx context.Context, language string) *ModelChoice {
+	pool, stats := t.pool(ctx, language, func(ModelChoice) bool { return true })
+	if len(pool) < 2 {
+		return nil
+	}
+	return best(pool, stats)
+}
+
+func best(pool []ModelChoice, stats map[string]ModelAcceptance) *ModelChoice {
+	var pick *ModelChoice
+	bestRate := -1.0
+	for i := range pool {
+		row, ok := stats[pool[i].key()]
+		if !ok || row.Shown < tunerMinSamples {
+			continue
+		}
+		if row.AcceptanceRate > bestRate {
+			pick, bestRate = &pool[i], row.AcceptanceRate
+		}
+	}
+	return pick
+}
+
+// pool lists the available candidates and previously used models for a
+// language, with their acceptance stats keyed by ModelChoice.key.
+func (t *modelTuner) pool(ctx context.Context, language string, available func(ModelChoice) bool) ([]ModelChoice, map[string]ModelAcceptance) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshLocked(ctx)
+
+	stats := make(map[string]ModelAcceptance)
+	var pool []ModelChoice
+	seen := map[string]bool{}
+	add := func(choice ModelChoice) {
+		if seen[choice.key()] || !available(choice) {
+			return
+		}
+		seen[choice.key()] = true
+		pool = append(pool, choice)
+	}
+	for _, row := range t.stats[language] {
+		stats[ModelChoice{Provider: row.Provider, Model: row.Model}.key()] = row
+	}
+	for _, choice := range t.candidates {
+		add(choice)
+	}
+	for _, row := range t.stats[language] {
+		if row.Shown >= tunerMinSamples && row.Model != "" {
+			add(ModelChoice{Provider: row.Provider, Model: row.Model})
+		}
+	}
+	return pool, stats
+}
+
+func (t *modelTuner) refreshLocked(ctx context.Context) {
+	if t.service.db == nil || time.Since(t.loadedAt) < tunerRefresh {
+		return
+	}
+	t.loadedAt = time.Now()
+
+	db := t.service.db.WithContext(ctx).Where("created_at >= ?", time.Now().Add(-tunerWindow))
+	rows, err := acceptanceRows(db)
+	if err != nil {
+		log.Printf("completions: failed to load acceptance rates: %v", err)
+		return
+	}
+	stats := make(map[string][]ModelAcceptance)
+	for _, row := range rows {
+		stats[row.Language] = append(stats[row.Language], row)
+	}
+	t.stats = stats
+}
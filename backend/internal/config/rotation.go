@@ -13,13 +13,15 @@ import (
 
 // RotationResult tracks the outcome of a key rotation
 type RotationResult struct {
-	TotalSecrets    int       `json:"total_secrets"`
-	Migrated        int       `json:"migrated"`
-	Failed          int       `json:"failed"`
-	Errors          []string  `json:"errors,omitempty"`
-	StartedAt       time.Time `json:"started_at"`
-	CompletedAt     time.Time `json:"completed_at"`
-	DurationSeconds float64   `json:"duration_seconds"`
+	TotalSecrets     int       `json:"total_secrets"`
+	Migrated         int       `json:"migrated"`
+	Failed           int       `json:"failed"`
+	OrgEncrypted     int       `json:"org_encrypted"`      // Under organization keys; not re-encrypted
+	RewrappedOrgKeys int       `json:"rewrapped_org_keys"` // Local organization keys re-wrapped
+	Errors           []string  `json:"errors,omitempty"`
+	StartedAt        time.Time `json:"started_at"`
+	CompletedAt      time.Time `json:"completed_at"`
+	DurationSeconds  float64   `json:"duration_seconds"`
 }
 
 // RotateMasterKey re-encrypts all user secrets from oldKey to newKey.
@@ -65,6 +67,13 @@ func RotateMasterKey(db *gorm.DB, oldKeyBase64, newKeyBase64 string) (*RotationR
 	// Process in a transaction
 	txErr := db.Transaction(func(tx *gorm.DB) error {
 		for i, s := range allSecrets {
+			// Organization-encrypted values depend on their data key, not on
+			// the master key
+			if secrets.IsOrgCiphertext(s.EncryptedValue) {
+				result.OrgEncrypted++
+				continue
+			}
+
 			// Decrypt with old key
 			plaintext, err := oldManager.Decrypt(s.UserID, s.EncryptedValue, s.Salt)
 			if err != nil {
@@ -105,6 +114,13 @@ func RotateMasterKey(db *gorm.DB, oldKeyBase64, newKeyBase64 string) (*RotationR
 			}
 		}
 
+		// Organization data keys wrapped by the master key move with it
+		rewrapped, err := secrets.RewrapLocalOrgKeys(tx, oldManager, newManager)
+		if err != nil {
+			return fmt.Errorf("failed to re-wrap organization keys: %w", err)
+		}
+		result.RewrappedOrgKeys = rewrapped
+
 		// If more than 10% failed, roll back the entire transaction
 		if result.TotalSecrets > 0 && float64(result.Failed)/float64(result.TotalSecrets) > 0.1 {
 			return fmt.Errorf("too many failures (%d/%d) - rolling back", result.Failed, result.TotalSecrets)
@@ -140,6 +156,9 @@ func ValidateRotation(db *gorm.DB, newKeyBase64 string) (int, int, error) {
 
 	ok, fail := 0, 0
 	for _, s := range allSecrets {
+		if secrets.IsOrgCiphertext(s.EncryptedValue) {
+			continue
+		}
 		if _, err := newManager.Decrypt(s.UserID, s.EncryptedValue, s.Salt); err != nil {
 			fail++
 		} else {
@@ -59,7 +59,8 @@ type SecretsConfig struct {
 	JWTSecretOld     string // For rotation support
 
 	// Encryption
-	SecretsMasterKey string
+	SecretsMasterKey    string
+	SecretsMasterKeyOld string // Still decrypts while a rotation job runs
 
 	// External services
 	StripeSecretKey     string
@@ -211,6 +212,7 @@ func ValidateSecrets() (*SecretsConfig, error) {
 	config.JWTRefreshSecret = os.Getenv("JWT_REFRESH_SECRET")
 	config.JWTSecretOld = os.Getenv("JWT_SECRET_OLD") // For rotation support
 	config.SecretsMasterKey = os.Getenv("SECRETS_MASTER_KEY")
+	config.SecretsMasterKeyOld = os.Getenv("SECRETS_MASTER_KEY_OLD") // For rotation support
 	config.StripeSecretKey = os.Getenv("STRIPE_SECRET_KEY")
 	config.StripeWebhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
 	config.DatabaseURL = os.Getenv("DATABASE_URL")
@@ -363,6 +365,7 @@ func ValidateAndLogSecrets() (*SecretsConfig, error) {
 	logSecretStatus("JWT_REFRESH_SECRET", config.JWTRefreshSecret != "")
 	logSecretStatus("JWT_SECRET_OLD (rotation)", config.JWTSecretOld != "")
 	logSecretStatus("SECRETS_MASTER_KEY", config.SecretsMasterKey != "")
+	logSecretStatus("SECRETS_MASTER_KEY_OLD (rotation)", config.SecretsMasterKeyOld != "")
 	logSecretStatus("STRIPE_SECRET_KEY", config.StripeSecretKey != "")
 	logSecretStatus("STRIPE_WEBHOOK_SECRET", config.StripeWebhookSecret != "")
 	logSecretStatus("DATABASE_URL", config.DatabaseURL != "")
@@ -352,6 +352,9 @@ func (dm *DatabaseManager) createPostgreSQLDatabase(db *ManagedDatabase) error {
 
 // createRedisDatabase creates a new Redis database (namespace)
 func (dm *DatabaseManager) createRedisDatabase(db *ManagedDatabase) error {
+	if err := ValidateRedisName(db.Name); err != nil {
+		return err
+	}
 	if dm.redisHost == "" {
 		dm.redisHost = "localhost"
 		dm.redisPort = 6379
@@ -486,12 +489,8 @@ func (dm *DatabaseManager) DeleteDatabase(db *ManagedDatabase) error {
 	case DatabaseTypeRedis:
 		// Close connection if exists
 		if conn, exists := dm.redisConnections[db.ID]; exists {
-			// Delete all keys with the prefix
-			ctx := context.Background()
-			keys, _ := conn.Keys(ctx, db.DatabaseName+"*").Result()
-			if len(keys) > 0 {
-				conn.Del(ctx, keys...)
-			}
+			// Delete all keys of the namespace
+			flushNamespace(context.Background(), conn, db)
 			conn.Close()
 			delete(dm.redisConnections, db.ID)
 		}
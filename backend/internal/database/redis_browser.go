@@ -0,0 +1,496 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Limits of the Redis browser. Managed Redis namespaces share one server, so
+// every operation is confined to the namespace's key prefix and bounded.
+const (
+	// DefaultRedisPageSize is how many keys a page asks SCAN for
+	DefaultRedisPageSize = 100
+	// MaxRedisPageSize caps the SCAN count of one page
+	MaxRedisPageSize = 1000
+	// RedisInspectLimit caps the keys walked for queue lengths and memory
+	// stats; larger namespaces are reported as sampled
+	RedisInspectLimit = 10000
+	// DefaultRedisPeekWait is how long a channel peek listens by default
+	DefaultRedisPeekWait = 3 * time.Second
+	// MaxRedisPeekWait caps how long a channel peek listens
+	MaxRedisPeekWait = 10 * time.Second
+	// MaxRedisPeekMessages caps the messages one peek returns
+	MaxRedisPeekMessages = 100
+	// RedisPayloadPreviewBytes caps each peeked message payload
+	RedisPayloadPreviewBytes = 4096
+
+	redisLargestKeys = 10
+	redisFlushBatch  = 500
+)
+
+// ErrNotRedis is returned when a Redis operation targets another database type
+var ErrNotRedis = errors.New("database is not a Redis namespace")
+
+// ErrInvalidRedisName is returned for Redis database names containing ':'.
+// The name ends the namespace's key prefix, so "cache:jobs" would fall inside
+// the "cache" namespace and be browsed and flushed with it.
+var ErrInvalidRedisName = errors.New("redis database names cannot contain ':'")
+
+// ValidateRedisName checks a name can be used for a Redis namespace
+func ValidateRedisName(name string) error {
+	if strings.Contains(name, ":") {
+		return ErrInvalidRedisName
+	}
+	return nil
+}
+
+// RedisKey describes one key of a namespace. Key is relative to the
+// namespace prefix. TTLSeconds is -1 for keys without an expiry.
+type RedisKey struct {
+	Key        string `json:"key"`
+	Type       string `json:"type"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// RedisKeyPage is one SCAN page. A NextCursor of 0 means the scan is done;
+// pages can be empty before that.
+type RedisKeyPage struct {
+	Keys       []RedisKey `json:"keys"`
+	NextCursor uint64     `json:"next_cursor"`
+}
+
+// RedisQueue is a list, sorted set or stream with its length. Job queues
+// such as BullMQ, RQ and Celery keep their pending jobs in these.
+type RedisQueue struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Length int64  `json:"length"`
+}
+
+// RedisQueueReport lists a namespace's queues, longest first
+type RedisQueueReport struct {
+	Queues []RedisQueue `json:"queues"`
+	// Sampled is set when the namespace had more than RedisInspectLimit keys
+	Sampled bool `json:"sampled"`
+}
+
+// RedisChannel is a pub/sub channel with active subscribers
+type RedisChannel struct {
+	Name        string `json:"name"`
+	Subscribers int64  `json:"subscribers"`
+}
+
+// RedisMessage is a message received while peeking at a channel
+type RedisMessage struct {
+	Channel    string    `json:"channel"`
+	Payload    string    `json:"payload"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// RedisKeySize is a key and the memory it uses
+type RedisKeySize struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Bytes int64  `json:"bytes"`
+}
+
+// RedisMemoryStats is the memory a namespace uses
+type RedisMemoryStats struct {
+	Keys        int64            `json:"keys"`
+	MemoryBytes int64            `json:"memory_bytes"`
+	KeysByType  map[string]int64 `json:"keys_by_type"`
+	LargestKeys []RedisKeySize   `json:"largest_keys"`
+	LimitBytes  int64            `json:"limit_bytes"`
+	// Sampled is set when the namespace had more than RedisInspectLimit
+	// keys; counts and memory then cover only the keys walked
+	Sampled bool `json:"sampled"`
+	// EvictionPolicy is the server's maxmemory-policy, which decides what
+	// happens to keys when the shared server fills up
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+}
+
+// redisNamespacePrefix is the prefix of every key and channel of a namespace,
+// the same one processes receive in QUEUE_KEY_PREFIX
+func redisNamespacePrefix(db *ManagedDatabase) string {
+	return db.DatabaseName + ":"
+}
+
+// escapeRedisGlob escapes glob characters so s matches only itself in a
+// SCAN MATCH or PUBSUB CHANNELS pattern
+func escapeRedisGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// redisNamespacePattern confines a user pattern to the namespace
+func redisNamespacePattern(db *ManagedDatabase, pattern string) string {
+	if pattern == "" {
+		pattern = "*"
+	}
+	return escapeRedisGlob(redisNamespacePrefix(db)) + pattern
+}
+
+// redisClient returns the connection of a Redis namespace, connecting again
+// after a restart
+func (dm *DatabaseManager) redisClient(db *ManagedDatabase) (*redis.Client, error) {
+	if db.Type != DatabaseTypeRedis {
+		return nil, ErrNotRedis
+	}
+	dm.mu.RLock()
+	client, exists := dm.redisConnections[db.ID]
+	dm.mu.RUnlock()
+	if exists {
+		return client, nil
+	}
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if client, exists := dm.redisConnections[db.ID]; exists {
+		return client, nil
+	}
+	client = redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%d", db.Host, db.Port),
+		DB:   0,
+	})
+	dm.redisConnections[db.ID] = client
+	return client, nil
+}
+
+// scanNamespace walks up to limit keys of the namespace matching pattern
+// and reports whether it stopped early
+func scanNamespace(ctx context.Context, client *redis.Client, match string, limit int) ([]string, bool, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := client.Scan(ctx, cursor, match, MaxRedisPageSize).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		keys = append(keys, batch...)
+		if len(keys) >= limit {
+			return keys[:limit], true, nil
+		}
+		if next == 0 {
+			return keys, false, nil
+		}
+		cursor = next
+	}
+}
+
+// ScanRedisKeys returns one page of the namespace's keys matching pattern,
+// a glob relative to the namespace
+func (dm *DatabaseManager) ScanRedisKeys(ctx context.Context, db *ManagedDatabase, pattern string, cursor uint64, count int) (*RedisKeyPage, error) {
+	client, err := dm.redisClient(db)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		count = DefaultRedisPageSize
+	}
+	if count > MaxRedisPageSize {
+		count = MaxRedisPageSize
+	}
+	names, next, err := client.Scan(ctx, cursor, redisNamespacePattern(db, pattern), int64(count)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &RedisKeyPage{Keys: make([]RedisKey, 0, len(names)), NextCursor: next}
+	if len(names) == 0 {
+		return page, nil
+	}
+	pipe := client.Pipeline()
+	types := make([]*redis.StatusCmd, len(names))
+	ttls := make([]*redis.DurationCmd, len(names))
+	for i, name := range names {
+		types[i] = pipe.Type(ctx, name)
+		ttls[i] = pipe.TTL(ctx, name)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	prefix := redisNamespacePrefix(db)
+	for i, name := range names {
+		key := RedisKey{Key: strings.TrimPrefix(name, prefix), Type: types[i].Val(), TTLSeconds: -1}
+		if ttl := ttls[i].Val(); ttl > 0 {
+			key.TTLSeconds = int64(ttl / time.Second)
+		}
+		// Expired between SCAN and TYPE
+		if key.Type == "none" {
+			continue
+		}
+		page.Keys = append(page.Keys, key)
+	}
+	sort.Slice(page.Keys, func(i, j int) bool { return page.Keys[i].Key < page.Keys[j].Key })
+	return page, nil
+}
+
+// RedisQueues returns the lengths of the namespace's lists, sorted sets and
+// streams
+func (dm *DatabaseManager) RedisQueues(ctx context.Context, db *ManagedDatabase) (*RedisQueueReport, error) {
+	client, err := dm.redisClient(db)
+	if err != nil {
+		return nil, err
+	}
+	names, sampled, err := scanNamespace(ctx, client, redisNamespacePattern(db, "*"), RedisInspectLimit)
+	if err != nil {
+		return nil, err
+	}
+	report := &RedisQueueReport{Queues: []RedisQueue{}, Sampled: sampled}
+	types, err := redisTypes(ctx, client, names)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := client.Pipeline()
+	lengths := make(map[string]*redis.IntCmd)
+	for i, name := range names {
+		switch types[i] {
+		case "list":
+			lengths[name] = pipe.LLen(ctx, name)
+		case "zset":
+			lengths[name] = pipe.ZCard(ctx, name)
+		case "stream":
+			lengths[name] = pipe.XLen(ctx, name)
+		}
+	}
+	if len(lengths) == 0 {
+		return report, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	prefix := redisNamespacePrefix(db)
+	for i, name := range names {
+		cmd, ok := lengths[name]
+		if !ok {
+			continue
+		}
+		report.Queues = append(report.Queues, RedisQueue{
+			Key:    strings.TrimPrefix(name, prefix),
+			Type:   types[i],
+			Length: cmd.Val(),
+		})
+	}
+	sort.Slice(report.Queues, func(i, j int) bool {
+		if report.Queues[i].Length != report.Queues[j].Length {
+			return report.Queues[i].Length > report.Queues[j].Length
+		}
+		return report.Queues[i].Key < report.Queues[j].Key
+	})
+	return report, nil
+}
+
+// redisTypes returns the type of each key, "none" for keys that expired
+func redisTypes(ctx context.Context, client *redis.Client, names []string) ([]string, error) {
+	types := make([]string, len(names))
+	if len(names) == 0 {
+		return types, nil
+	}
+	pipe := client.Pipeline()
+	cmds := make([]*redis.StatusCmd, len(names))
+	for i, name := range names {
+		cmds[i] = pipe.Type(ctx, name)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		types[i] = cmd.Val()
+	}
+	return types, nil
+}
+
+// RedisChannels lists the namespace's pub/sub channels that have
+// subscribers, with channel names relative to the namespace
+func (dm *DatabaseManager) RedisChannels(ctx context.Context, db *ManagedDatabase, pattern string) ([]RedisChannel, error) {
+	client, err := dm.redisClient(db)
+	if err != nil {
+		return nil, err
+	}
+	names, err := client.PubSubChannels(ctx, redisNamespacePattern(db, pattern)).Result()
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]RedisChannel, 0, len(names))
+	if len(names) == 0 {
+		return channels, nil
+	}
+	counts, err := client.PubSubNumSub(ctx, names...).Result()
+	if err != nil {
+		return nil, err
+	}
+	prefix := redisNamespacePrefix(db)
+	for _, name := range names {
+		channels = append(channels, RedisChannel{Name: strings.TrimPrefix(name, prefix), Subscribers: counts[name]})
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+	return channels, nil
+}
+
+// PeekRedisChannel subscribes to a channel of the namespace for up to wait
+// and returns the messages published meanwhile, at most max of them
+func (dm *DatabaseManager) PeekRedisChannel(ctx context.Context, db *ManagedDatabase, channel string, wait time.Duration, max int) ([]RedisMessage, error) {
+	client, err := dm.redisClient(db)
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+	if wait <= 0 {
+		wait = DefaultRedisPeekWait
+	}
+	if wait > MaxRedisPeekWait {
+		wait = MaxRedisPeekWait
+	}
+	if max <= 0 || max > MaxRedisPeekMessages {
+		max = MaxRedisPeekMessages
+	}
+
+	prefix := redisNamespacePrefix(db)
+	sub := client.Subscribe(ctx, prefix+channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return nil, err
+	}
+
+	messages := []RedisMessage{}
+	deadline := time.Now().Add(wait)
+	for len(messages) < max {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		received, err := sub.ReceiveTimeout(ctx, remaining)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		msg, ok := received.(*redis.Message)
+		if !ok {
+			continue
+		}
+		message := RedisMessage{
+			Channel:    strings.TrimPrefix(msg.Channel, prefix),
+			Payload:    msg.Payload,
+			ReceivedAt: time.Now(),
+		}
+		if len(message.Payload) > RedisPayloadPreviewBytes {
+			message.Payload = message.Payload[:RedisPayloadPreviewBytes]
+			message.Truncated = true
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// RedisMemory reports the memory the namespace's keys use
+func (dm *DatabaseManager) RedisMemory(ctx context.Context, db *ManagedDatabase) (*RedisMemoryStats, error) {
+	client, err := dm.redisClient(db)
+	if err != nil {
+		return nil, err
+	}
+	names, sampled, err := scanNamespace(ctx, client, redisNamespacePattern(db, "*"), RedisInspectLimit)
+	if err != nil {
+		return nil, err
+	}
+	stats := &RedisMemoryStats{
+		KeysByType:  map[string]int64{},
+		LargestKeys: []RedisKeySize{},
+		LimitBytes:  int64(db.MaxStorageMB) * 1024 * 1024,
+		Sampled:     sampled,
+	}
+	if policy, err := client.ConfigGet(ctx, "maxmemory-policy").Result(); err == nil && len(policy) == 2 {
+		stats.EvictionPolicy, _ = policy[1].(string)
+	}
+
+	types, err := redisTypes(ctx, client, names)
+	if err != nil {
+		return nil, err
+	}
+	pipe := client.Pipeline()
+	usage := make([]*redis.IntCmd, len(names))
+	for i, name := range names {
+		usage[i] = pipe.MemoryUsage(ctx, name)
+	}
+	if len(names) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+	}
+
+	prefix := redisNamespacePrefix(db)
+	sizes := make([]RedisKeySize, 0, len(names))
+	for i, name := range names {
+		if types[i] == "none" {
+			continue
+		}
+		stats.Keys++
+		stats.KeysByType[types[i]]++
+		bytes := usage[i].Val()
+		stats.MemoryBytes += bytes
+		sizes = append(sizes, RedisKeySize{Key: strings.TrimPrefix(name, prefix), Type: types[i], Bytes: bytes})
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].Bytes > sizes[j].Bytes })
+	if len(sizes) > redisLargestKeys {
+		sizes = sizes[:redisLargestKeys]
+	}
+	stats.LargestKeys = sizes
+	return stats, nil
+}
+
+// FlushRedisNamespace deletes every key of the namespace and returns how
+// many were deleted. Other namespaces on the server are untouched.
+func (dm *DatabaseManager) FlushRedisNamespace(ctx context.Context, db *ManagedDatabase) (int64, error) {
+	client, err := dm.redisClient(db)
+	if err != nil {
+		return 0, err
+	}
+	return flushNamespace(ctx, client, db)
+}
+
+// flushNamespace unlinks the namespace's keys in SCAN batches
+func flushNamespace(ctx context.Context, client *redis.Client, db *ManagedDatabase) (int64, error) {
+	match := redisNamespacePattern(db, "*")
+	var deleted int64
+	var cursor uint64
+	for {
+		names, next, err := client.Scan(ctx, cursor, match, redisFlushBatch).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(names) > 0 {
+			n, err := client.Unlink(ctx, names...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
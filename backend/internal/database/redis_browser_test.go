@@ -0,0 +1,297 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisNamespacePatternEscapesThePrefix(t *testing.T) {
+	db := &ManagedDatabase{Type: DatabaseTypeRedis, ProjectID: 3, DatabaseName: "apex:project_3:cache[v2]"}
+	cases := map[string]string{
+		"":       `apex:project_3:cache\[v2\]:*`,
+		"jobs:*": `apex:project_3:cache\[v2\]:jobs:*`,
+	}
+	for pattern, want := range cases {
+		if got := redisNamespacePattern(db, pattern); got != want {
+			t.Fatalf("redisNamespacePattern(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+	if got := escapeRedisGlob(`a*b?c\d`); got != `a\*b\?c\\d` {
+		t.Fatalf("escapeRedisGlob() = %q", got)
+	}
+}
+
+func TestRedisBrowserRejectsOtherDatabaseTypes(t *testing.T) {
+	dm := &DatabaseManager{}
+	if _, err := dm.ScanRedisKeys(context.Background(), &ManagedDatabase{Type: DatabaseTypePostgreSQL}, "*", 0, 0); err != ErrNotRedis {
+		t.Fatalf("ScanRedisKeys() on postgres = %v, want ErrNotRedis", err)
+	}
+}
+
+func TestValidateRedisNameRejectsColons(t *testing.T) {
+	if err := ValidateRedisName("cache_v2"); err != nil {
+		t.Fatalf("ValidateRedisName(cache_v2) = %v", err)
+	}
+	if err := ValidateRedisName("cache:jobs"); !errors.Is(err, ErrInvalidRedisName) {
+		t.Fatalf("ValidateRedisName(cache:jobs) = %v, want ErrInvalidRedisName", err)
+	}
+	dm := &DatabaseManager{}
+	if err := dm.createRedisDatabase(&ManagedDatabase{Type: DatabaseTypeRedis, ProjectID: 1, Name: "cache:jobs"}); !errors.Is(err, ErrInvalidRedisName) {
+		t.Fatalf("createRedisDatabase() = %v, want ErrInvalidRedisName", err)
+	}
+}
+
+// fakeRedis serves the few commands the browser sends over RESP. SCAN walks
+// the sorted keyspace COUNT keys at a time and returns the ones matching, so
+// like a real server it can return short or empty pages before the end.
+type fakeRedis struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func startFakeRedis(t *testing.T, keys ...string) (*fakeRedis, *net.TCPAddr) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	f := &fakeRedis{keys: map[string]bool{}}
+	for _, key := range keys {
+		f.keys[key] = true
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, listener.Addr().(*net.TCPAddr)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.handle(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func respArray(items []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(items))
+	for _, item := range items {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(item), item)
+	}
+	return b.String()
+}
+
+func (f *fakeRedis) handle(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "TYPE":
+		if f.keys[args[1]] {
+			return "+string\r\n"
+		}
+		return "+none\r\n"
+	case "TTL":
+		return ":-1\r\n"
+	case "UNLINK":
+		deleted := 0
+		for _, key := range args[1:] {
+			if f.keys[key] {
+				delete(f.keys, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "SCAN":
+		cursor, _ := strconv.Atoi(args[1])
+		match, count := "*", 10
+		for i := 2; i+1 < len(args); i += 2 {
+			switch strings.ToUpper(args[i]) {
+			case "MATCH":
+				match = args[i+1]
+			case "COUNT":
+				count, _ = strconv.Atoi(args[i+1])
+			}
+		}
+		all := make([]string, 0, len(f.keys))
+		for key := range f.keys {
+			all = append(all, key)
+		}
+		sort.Strings(all)
+		end := cursor + count
+		if end >= len(all) {
+			end = len(all)
+		}
+		found := []string{}
+		for _, key := range all[min(cursor, len(all)):end] {
+			if ok, _ := path.Match(match, key); ok {
+				found = append(found, key)
+			}
+		}
+		next := end
+		if end == len(all) {
+			next = 0
+		}
+		return "*2\r\n" + fmt.Sprintf("$%d\r\n%d\r\n", len(strconv.Itoa(next)), next) + respArray(found)
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (f *fakeRedis) remaining() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.keys))
+	for key := range f.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// redisBrowserFixture seeds a namespace and neighbours whose names share its
+// prefix without being inside it
+func redisBrowserFixture(t *testing.T) (*DatabaseManager, *ManagedDatabase, *fakeRedis, []string) {
+	t.Helper()
+	var keys, others []string
+	for i := 0; i < 25; i++ {
+		keys = append(keys, fmt.Sprintf("apex:project_1:cache:job:%02d", i))
+	}
+	others = append(others,
+		"apex:project_1:cache2:job:01",
+		"apex:project_1:cachejob",
+		"apex:project_11:cache:job:01",
+		"apex:project_2:cache:job:01",
+		"other:key",
+	)
+	sort.Strings(others)
+	fake, addr := startFakeRedis(t, append(append([]string{}, keys...), others...)...)
+	dm := &DatabaseManager{redisConnections: map[uint]*redis.Client{}}
+	db := &ManagedDatabase{ID: 1, Type: DatabaseTypeRedis, ProjectID: 1, Name: "cache", DatabaseName: "apex:project_1:cache", Host: addr.IP.String(), Port: addr.Port}
+	t.Cleanup(func() {
+		for _, client := range dm.redisConnections {
+			client.Close()
+		}
+	})
+	return dm, db, fake, others
+}
+
+func TestScanRedisKeysPagesThroughOnlyTheNamespace(t *testing.T) {
+	dm, db, _, _ := redisBrowserFixture(t)
+	ctx := context.Background()
+
+	var got []string
+	var cursor uint64
+	pages := 0
+	for {
+		page, err := dm.ScanRedisKeys(ctx, db, "", cursor, 7)
+		if err != nil {
+			t.Fatalf("ScanRedisKeys() error = %v", err)
+		}
+		pages++
+		for _, key := range page.Keys {
+			got = append(got, key.Key)
+			if key.Type != "string" || key.TTLSeconds != -1 {
+				t.Fatalf("key = %+v", key)
+			}
+		}
+		if page.NextCursor == 0 {
+			break
+		}
+		cursor = page.NextCursor
+		if pages > 10 {
+			t.Fatal("scan did not finish")
+		}
+	}
+	if pages < 2 {
+		t.Fatalf("expected several pages, got %d", pages)
+	}
+	if len(got) != 25 || got[0] != "job:00" || got[24] != "job:24" {
+		t.Fatalf("scanned keys = %v", got)
+	}
+
+	page, err := dm.ScanRedisKeys(ctx, db, "job:1*", 0, MaxRedisPageSize)
+	if err != nil || len(page.Keys) != 10 || page.NextCursor != 0 {
+		t.Fatalf("ScanRedisKeys(job:1*) = %+v, %v", page, err)
+	}
+}
+
+func TestFlushRedisNamespaceLeavesOtherNamespacesAlone(t *testing.T) {
+	dm, db, fake, others := redisBrowserFixture(t)
+
+	deleted, err := dm.FlushRedisNamespace(context.Background(), db)
+	if err != nil {
+		t.Fatalf("FlushRedisNamespace() error = %v", err)
+	}
+	if deleted != 25 {
+		t.Fatalf("deleted %d keys, want 25", deleted)
+	}
+	if left := fake.remaining(); strings.Join(left, ",") != strings.Join(others, ",") {
+		t.Fatalf("remaining keys = %v, want %v", left, others)
+	}
+
+	// Deleting the database clears the namespace the same way
+	fake.mu.Lock()
+	fake.keys["apex:project_1:cache:again"] = true
+	fake.mu.Unlock()
+	if err := dm.DeleteDatabase(db); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if left := fake.remaining(); strings.Join(left, ",") != strings.Join(others, ",") {
+		t.Fatalf("remaining keys after delete = %v, want %v", left, others)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	manageddb "apex-build/internal/database"
 	"apex-build/internal/git"
 	"apex-build/internal/hosting"
+	"apex-build/internal/logdrain"
 	"apex-build/internal/mcp"
 	"apex-build/internal/mobile"
 	"apex-build/internal/secrets"
@@ -329,10 +330,15 @@ func (d *Database) Migrate() error {
 		// Secrets management
 		&secrets.Secret{},
 		&secrets.SecretAuditLog{},
+		&secrets.OrgDataKey{},
+		&secrets.RotationJob{},
 		// MCP server integration
 		&mcp.ExternalMCPServer{},
 		// Git integration
 		&git.Repository{},
+		&git.SyncedFile{},
+		&git.SyncConflict{},
+		&git.IssueBuild{},
 		// Managed Database Service (auto-provisioned PostgreSQL per project)
 		&manageddb.ManagedDatabase{},
 		// BYOK (Bring Your Own Key) management
@@ -349,6 +355,12 @@ func (d *Database) Migrate() error {
 		&hosting.CustomDomain{},
 		&hosting.DeploymentEvent{},
 		&hosting.SSLCertificate{},
+		&hosting.WorkerProcess{},
+		&hosting.ErrorGroup{},
+		&hosting.ErrorEvent{},
+		&hosting.CSPViolation{},
+		&hosting.DeploymentLoadSample{},
+		&logdrain.Drain{},
 		// Completed build history (persist builds across restarts)
 		&models.CompletedBuild{},
 		&mobile.MobileBuildRecord{},
@@ -359,6 +371,8 @@ func (d *Database) Migrate() error {
 		&models.PromptPackActivationEvent{},
 		// User-uploaded assets for AI agents (images, CSVs, PDFs, etc.)
 		&models.ProjectAsset{},
+		// Resumable chunked uploads for large project files
+		&models.FileUpload{},
 		// Stripe webhook idempotency and credit audit trail
 		&models.ProcessedStripeEvent{},
 		&models.CreditLedgerEntry{},
@@ -5,6 +5,7 @@ package deploy
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,8 @@ import (
 	"sync"
 	"time"
 
+	"apex-build/internal/pagination"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -100,6 +103,10 @@ type DeploymentConfig struct {
 	RootDirectory string                 `json:"root_directory,omitempty"`
 	Database      *DatabaseConfig        `json:"database,omitempty"`
 	Custom        map[string]interface{} `json:"custom,omitempty"`
+
+	// Files pins the deployment to a snapshot instead of the project's
+	// current files, so a promoted artifact deploys byte-for-byte
+	Files []ProjectFile `json:"-"`
 }
 
 // DatabaseConfig contains configuration for an optional managed database
@@ -182,6 +189,10 @@ type DeploymentService struct {
 	mu        sync.RWMutex
 	active    map[string]context.CancelFunc // active deployment cancellation functions
 
+	provenanceKey  ed25519.PrivateKey // signs provenance attestations; nil leaves them unsigned
+	statusObserver StatusObserver     // told about status changes; may be nil
+	preDeployHooks PreDeployHooks     // runs apex.yaml pre_deploy hooks; may be nil
+
 	monitorPollInterval time.Duration
 }
 
@@ -291,32 +302,16 @@ func (s *DeploymentService) executeDeployment(deployment *Deployment, config *De
 	s.updateStatus(deployment, StatusPreparing, "")
 	s.addLog(deployment.ID, "info", "Preparing deployment...", "prepare")
 
-	// Get project files from database
-	var files []struct {
-		Path     string
-		Content  string
-		Size     int64
-		MimeType string
-		Type     string
-	}
-	if err := s.db.Table("files").
-		Select("path, content, size, mime_type, type").
-		Where("project_id = ?", config.ProjectID).
-		Find(&files).Error; err != nil {
-		s.failDeployment(deployment, fmt.Sprintf("Failed to fetch project files: %v", err))
-		return
-	}
-
-	// Convert to ProjectFile format
-	projectFiles := make([]ProjectFile, len(files))
-	for i, f := range files {
-		projectFiles[i] = ProjectFile{
-			Path:     f.Path,
-			Content:  f.Content,
-			Size:     f.Size,
-			MimeType: f.MimeType,
-			IsDir:    f.Type == "directory",
+	projectFiles := config.Files
+	if projectFiles != nil {
+		s.addLog(deployment.ID, "info", fmt.Sprintf("Deploying pinned artifact (%d files)", len(projectFiles)), "prepare")
+	} else {
+		files, err := s.loadProjectFiles(config.ProjectID)
+		if err != nil {
+			s.failDeployment(deployment, fmt.Sprintf("Failed to fetch project files: %v", err))
+			return
 		}
+		projectFiles = files
 	}
 
 	// Detect project type and prepare build
@@ -354,6 +349,10 @@ func (s *DeploymentService) executeDeployment(deployment *Deployment, config *De
 		s.addLog(deployment.ID, "info", fmt.Sprintf("Managed %s database ready; runtime env updated", config.Database.Provider), "prepare")
 	}
 
+	if !s.runPreDeployHooks(ctx, deployment, projectFiles) {
+		return
+	}
+
 	s.addLog(deployment.ID, "info", fmt.Sprintf("Build command: %s", config.BuildCommand), "prepare")
 	s.addLog(deployment.ID, "info", fmt.Sprintf("Output directory: %s", config.OutputDir), "prepare")
 
@@ -373,6 +372,12 @@ func (s *DeploymentService) executeDeployment(deployment *Deployment, config *De
 	deployment.BuildTime = buildDuration
 	s.addLog(deployment.ID, "info", fmt.Sprintf("Build completed in %dms", buildDuration), "build")
 
+	if provenance, err := s.recordProvenance(deployment, config, projectFiles, packageData, buildStartTime, time.Now()); err != nil {
+		s.addLog(deployment.ID, "warn", fmt.Sprintf("Failed to record provenance attestation: %v", err), "build")
+	} else {
+		s.addLog(deployment.ID, "info", fmt.Sprintf("Provenance attestation recorded for %d artifacts", len(provenance.Statement.Subject)), "build")
+	}
+
 	// Update status to deploying
 	s.updateStatus(deployment, StatusDeploying, "")
 	deployStartTime := time.Now()
@@ -420,6 +425,35 @@ func (s *DeploymentService) executeDeployment(deployment *Deployment, config *De
 	}
 }
 
+// loadProjectFiles reads a project's current files from the database
+func (s *DeploymentService) loadProjectFiles(projectID uint) ([]ProjectFile, error) {
+	var files []struct {
+		Path     string
+		Content  string
+		Size     int64
+		MimeType string
+		Type     string
+	}
+	if err := s.db.Table("files").
+		Select("path, content, size, mime_type, type").
+		Where("project_id = ?", projectID).
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	projectFiles := make([]ProjectFile, len(files))
+	for i, f := range files {
+		projectFiles[i] = ProjectFile{
+			Path:     f.Path,
+			Content:  f.Content,
+			Size:     f.Size,
+			MimeType: f.MimeType,
+			IsDir:    f.Type == "directory",
+		}
+	}
+	return projectFiles, nil
+}
+
 // GetDeploymentStatus returns the current status of a deployment
 func (s *DeploymentService) GetDeploymentStatus(deploymentID string) (*Deployment, error) {
 	var deployment Deployment
@@ -460,6 +494,7 @@ func (s *DeploymentService) CancelDeployment(deploymentID string) error {
 	}
 
 	s.addLog(deploymentID, "warn", "Deployment cancelled by user", "")
+	s.notifyStatus(&deployment)
 	return nil
 }
 
@@ -495,6 +530,40 @@ func (s *DeploymentService) GetDeploymentHistory(projectID uint, page, limit int
 	return deployments, total, nil
 }
 
+// ListDeploymentHistory returns one page of a project's deployments for a
+// parsed list request, with the project's total deployment count.
+func (s *DeploymentService) ListDeploymentHistory(projectID uint, list *pagination.Request) ([]Deployment, pagination.Info, int64, error) {
+	var deployments []Deployment
+	var total int64
+
+	s.db.Model(&Deployment{}).Where("project_id = ?", projectID).Count(&total)
+
+	if err := list.Apply(s.db.Where("project_id = ?", projectID)).Find(&deployments).Error; err != nil {
+		return nil, pagination.Info{}, 0, err
+	}
+	deployments, info := pagination.Page(list, deployments, func(d Deployment) (any, any) {
+		switch list.Sort.Key {
+		case "total_time":
+			return d.TotalTime, d.ID
+		case "status":
+			return string(d.Status), d.ID
+		}
+		return d.CreatedAt, d.ID
+	})
+	return deployments, info, total, nil
+}
+
+// DeploymentListSpec is the pagination spec for deployment history.
+var DeploymentListSpec = pagination.Spec{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "total_time", Column: "total_time", Desc: true},
+		{Key: "status", Column: "status"},
+	},
+}
+
 // GetAvailableProviders returns configured providers
 func (s *DeploymentService) GetAvailableProviders() []map[string]interface{} {
 	providers := make([]map[string]interface{}, 0)
@@ -537,6 +606,7 @@ func (s *DeploymentService) updateStatus(deployment *Deployment, status Deployme
 		deployment.ErrorMessage = errorMsg
 	}
 	s.db.Save(deployment)
+	s.notifyStatus(deployment)
 }
 
 func (s *DeploymentService) failDeployment(deployment *Deployment, errorMsg string) {
@@ -0,0 +1,404 @@
+// APEX.BUILD Deployment Cost Estimation
+// Projects monthly hosting cost per provider before anything is deployed
+
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ProviderNative is native .apex.app hosting in cost estimates. It is not a
+// deploy provider; native deploys go through the hosting service.
+const ProviderNative DeploymentProvider = "native"
+
+// PricesAsOf is when the provider list prices below were last checked
+const PricesAsOf = "2026-09"
+
+const (
+	// DefaultMonthlyPageViews is assumed when the caller gives no traffic
+	DefaultMonthlyPageViews int64 = 10000
+	MaxMonthlyPageViews     int64 = 1_000_000_000
+
+	// Browsers cache assets and lazy-load routes, so a page view transfers
+	// at most this much of the bundle
+	maxPageWeightBytes int64 = 2 << 20
+)
+
+// Workload is what a project needs from its host
+type Workload string
+
+const (
+	// WorkloadStatic is files only: any host serves it
+	WorkloadStatic Workload = "static"
+	// WorkloadServerRendered renders on request; serverless functions suffice
+	WorkloadServerRendered Workload = "server_rendered"
+	// WorkloadServer is a long-running process such as an Express or Flask app
+	WorkloadServer Workload = "server"
+)
+
+// ProjectProfile is what an estimate knows about a project
+type ProjectProfile struct {
+	ProjectType ProjectType `json:"project_type"`
+	Workload    Workload    `json:"workload"`
+	FileCount   int         `json:"file_count"`
+	// BundleBytes is the size of the files a deploy uploads, excluding
+	// dependencies and VCS metadata
+	BundleBytes int64 `json:"bundle_bytes"`
+	// AlwaysOn means the app cannot sleep or run as short-lived functions:
+	// it holds WebSockets, runs schedules or processes queues
+	AlwaysOn        bool     `json:"always_on"`
+	AlwaysOnReasons []string `json:"always_on_reasons,omitempty"`
+}
+
+// EstimateOptions are the caller's assumptions
+type EstimateOptions struct {
+	MonthlyPageViews int64
+	// AlwaysOn forces an always-on requirement the files don't reveal
+	AlwaysOn bool
+	// NativeIncluded is true when the user's plan already covers native
+	// hosting; otherwise native costs NativePlanUSD
+	NativeIncluded bool
+	NativePlanUSD  float64
+	NativePlanName string
+}
+
+// CostEstimate is the projected monthly cost on one provider
+type CostEstimate struct {
+	Provider DeploymentProvider `json:"provider"`
+	Name     string             `json:"name"`
+	// Available is true when this server can deploy to the provider
+	Available   bool     `json:"available"`
+	Viable      bool     `json:"viable"`
+	Reason      string   `json:"reason,omitempty"`
+	Plan        string   `json:"plan,omitempty"`
+	MonthlyUSD  float64  `json:"monthly_usd"`
+	BandwidthGB float64  `json:"bandwidth_gb"`
+	Notes       []string `json:"notes,omitempty"`
+}
+
+// Estimate compares providers for a project, cheapest viable first
+type Estimate struct {
+	ProjectID        uint               `json:"project_id"`
+	Profile          ProjectProfile     `json:"profile"`
+	MonthlyPageViews int64              `json:"monthly_page_views"`
+	Providers        []CostEstimate     `json:"providers"`
+	Recommended      DeploymentProvider `json:"recommended,omitempty"`
+	PricesAsOf       string             `json:"prices_as_of"`
+}
+
+// hostingPlan is one published plan of a provider
+type hostingPlan struct {
+	name       string
+	monthlyUSD float64
+	// includedGB of bandwidth; overagePerGB of zero means the plan stops
+	// serving past it and the next plan is needed
+	includedGB   float64
+	overagePerGB float64
+	workloads    []Workload
+	// sleeps when idle, or runs requests as short-lived functions; either
+	// way it cannot hold always-on work
+	sleeps     bool
+	serverless bool
+	notes      []string
+}
+
+func (p hostingPlan) serves(w Workload) bool {
+	for _, candidate := range p.workloads {
+		if candidate == w {
+			return true
+		}
+	}
+	return false
+}
+
+// providerPlans are list prices for a single project, cheapest first
+var providerPlans = map[DeploymentProvider][]hostingPlan{
+	ProviderVercel: {
+		{name: "Hobby", includedGB: 100, workloads: []Workload{WorkloadStatic, WorkloadServerRendered}, serverless: true,
+			notes: []string{"Hobby is limited to non-commercial use"}},
+		{name: "Pro", monthlyUSD: 20, includedGB: 1000, overagePerGB: 0.15, workloads: []Workload{WorkloadStatic, WorkloadServerRendered}, serverless: true,
+			notes: []string{"Priced per team member"}},
+	},
+	ProviderNetlify: {
+		{name: "Free", includedGB: 100, workloads: []Workload{WorkloadStatic, WorkloadServerRendered}, serverless: true},
+		{name: "Pro", monthlyUSD: 19, includedGB: 1000, overagePerGB: 0.55, workloads: []Workload{WorkloadStatic, WorkloadServerRendered}, serverless: true,
+			notes: []string{"Priced per team member"}},
+	},
+	ProviderRender: {
+		{name: "Static Site", includedGB: 100, overagePerGB: 0.15, workloads: []Workload{WorkloadStatic}},
+		{name: "Free Web Service", includedGB: 100, workloads: []Workload{WorkloadServerRendered, WorkloadServer}, sleeps: true,
+			notes: []string{"Spins down after 15 minutes without traffic; the next request waits for a cold start"}},
+		{name: "Starter Web Service", monthlyUSD: 7, includedGB: 100, overagePerGB: 0.15, workloads: []Workload{WorkloadServerRendered, WorkloadServer}},
+	},
+}
+
+// alwaysOnDependencies mark work that outlives a request
+var alwaysOnDependencies = map[string]string{
+	"ws":          "WebSocket server (ws)",
+	"socket.io":   "WebSocket server (socket.io)",
+	"node-cron":   "scheduled jobs (node-cron)",
+	"cron":        "scheduled jobs (cron)",
+	"bull":        "queue worker (bull)",
+	"bullmq":      "queue worker (bullmq)",
+	"agenda":      "scheduled jobs (agenda)",
+	"celery":      "queue worker (celery)",
+	"channels":    "WebSocket server (django channels)",
+	"websockets":  "WebSocket server (websockets)",
+	"apscheduler": "scheduled jobs (apscheduler)",
+	"rq":          "queue worker (rq)",
+}
+
+// skippedBundleDirs are not uploaded by a deploy
+var skippedBundleDirs = []string{"node_modules/", ".git/", ".next/cache/", "__pycache__/", ".venv/", "venv/", "target/"}
+
+// ProfileProject inspects project files for what hosting they need
+func ProfileProject(files []ProjectFile) ProjectProfile {
+	normalized := make([]ProjectFile, 0, len(files))
+	fileMap := make(map[string]string, len(files))
+	profile := ProjectProfile{}
+	for _, f := range files {
+		f.Path = "/" + strings.TrimPrefix(f.Path, "/")
+		normalized = append(normalized, f)
+		if f.IsDir || bundleSkipped(f.Path) {
+			continue
+		}
+		fileMap[f.Path] = f.Content
+		profile.FileCount++
+		size := f.Size
+		if size == 0 {
+			size = int64(len(f.Content))
+		}
+		profile.BundleBytes += size
+	}
+
+	profile.ProjectType = NewBuildService().DetectProjectType(normalized)
+	profile.Workload = projectWorkload(profile.ProjectType, fileMap)
+	profile.AlwaysOnReasons = alwaysOnReasons(fileMap)
+	profile.AlwaysOn = len(profile.AlwaysOnReasons) > 0
+	return profile
+}
+
+func bundleSkipped(path string) bool {
+	trimmed := strings.TrimPrefix(path, "/")
+	for _, dir := range skippedBundleDirs {
+		if strings.HasPrefix(trimmed, dir) || strings.Contains(trimmed, "/"+dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func projectWorkload(projectType ProjectType, fileMap map[string]string) Workload {
+	switch projectType {
+	case ProjectTypeStaticHTML, ProjectTypeReact, ProjectTypeVue, ProjectTypeAngular:
+		return WorkloadStatic
+	case ProjectTypeSvelte:
+		if strings.Contains(fileMap["/package.json"], "@sveltejs/kit") {
+			return WorkloadServerRendered
+		}
+		return WorkloadStatic
+	case ProjectTypeNextJS, ProjectTypeNuxt:
+		return WorkloadServerRendered
+	case ProjectTypeUnknown:
+		if _, ok := fileMap["/index.html"]; ok {
+			return WorkloadStatic
+		}
+	}
+	return WorkloadServer
+}
+
+func alwaysOnReasons(fileMap map[string]string) []string {
+	deps := make(map[string]bool)
+	if content, ok := fileMap["/package.json"]; ok {
+		var pkg struct {
+			Dependencies map[string]string `json:"dependencies"`
+		}
+		if json.Unmarshal([]byte(content), &pkg) == nil {
+			for dep := range pkg.Dependencies {
+				deps[dep] = true
+			}
+		}
+	}
+	if content, ok := fileMap["/requirements.txt"]; ok {
+		for _, line := range strings.Split(content, "\n") {
+			name := strings.ToLower(strings.TrimSpace(line))
+			if i := strings.IndexAny(name, "=<>~![; "); i >= 0 {
+				name = name[:i]
+			}
+			if name != "" && !strings.HasPrefix(name, "#") {
+				deps[name] = true
+			}
+		}
+	}
+
+	var reasons []string
+	for dep, reason := range alwaysOnDependencies {
+		if deps[dep] {
+			reasons = append(reasons, reason)
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// EstimateCost profiles a project's current files and prices native
+// hosting and each provider for it
+func (s *DeploymentService) EstimateCost(projectID uint, opts EstimateOptions) (*Estimate, error) {
+	files, err := s.loadProjectFiles(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	available := make([]DeploymentProvider, 0, len(s.providers))
+	for provider := range s.providers {
+		available = append(available, provider)
+	}
+	s.mu.RUnlock()
+
+	estimate := EstimateCosts(ProfileProject(files), opts, available)
+	estimate.ProjectID = projectID
+	return estimate, nil
+}
+
+// EstimateCosts projects the monthly cost of hosting a profiled project on
+// native hosting and each provider. available lists the providers this
+// server can deploy to.
+func EstimateCosts(profile ProjectProfile, opts EstimateOptions, available []DeploymentProvider) *Estimate {
+	views := opts.MonthlyPageViews
+	if views <= 0 {
+		views = DefaultMonthlyPageViews
+	}
+	if views > MaxMonthlyPageViews {
+		views = MaxMonthlyPageViews
+	}
+	if opts.AlwaysOn && !profile.AlwaysOn {
+		profile.AlwaysOn = true
+		profile.AlwaysOnReasons = append(profile.AlwaysOnReasons, "requested")
+	}
+
+	pageWeight := profile.BundleBytes
+	if pageWeight > maxPageWeightBytes {
+		pageWeight = maxPageWeightBytes
+	}
+	bandwidthGB := math.Round(float64(pageWeight)*float64(views)/1e9*100) / 100
+
+	isAvailable := make(map[DeploymentProvider]bool, len(available))
+	for _, p := range available {
+		isAvailable[p] = true
+	}
+
+	estimates := []CostEstimate{nativeEstimate(opts, bandwidthGB)}
+	for _, provider := range []DeploymentProvider{ProviderVercel, ProviderNetlify, ProviderRender} {
+		estimate := estimateProvider(provider, providerPlans[provider], profile, bandwidthGB)
+		estimate.Available = isAvailable[provider]
+		estimates = append(estimates, estimate)
+	}
+
+	// Cheapest viable first; ties keep native, then table order
+	sort.SliceStable(estimates, func(i, j int) bool {
+		if estimates[i].Viable != estimates[j].Viable {
+			return estimates[i].Viable
+		}
+		return estimates[i].MonthlyUSD < estimates[j].MonthlyUSD
+	})
+
+	result := &Estimate{
+		Profile:          profile,
+		MonthlyPageViews: views,
+		Providers:        estimates,
+		PricesAsOf:       PricesAsOf,
+	}
+	for _, e := range estimates {
+		if e.Viable && e.Available {
+			result.Recommended = e.Provider
+			break
+		}
+	}
+	return result
+}
+
+// nativeEstimate prices native hosting, which serves every workload and
+// does not meter bandwidth
+func nativeEstimate(opts EstimateOptions, bandwidthGB float64) CostEstimate {
+	estimate := CostEstimate{
+		Provider:    ProviderNative,
+		Name:        "APEX Hosting (.apex.app)",
+		Available:   true,
+		Viable:      true,
+		BandwidthGB: bandwidthGB,
+	}
+	if opts.NativeIncluded {
+		estimate.Plan = "Included in your plan"
+		estimate.Notes = []string{"No extra charge on your current plan"}
+		return estimate
+	}
+	estimate.Plan = opts.NativePlanName
+	estimate.MonthlyUSD = opts.NativePlanUSD
+	estimate.Notes = []string{"Requires a paid backend plan, which also includes AI credits"}
+	return estimate
+}
+
+// estimateProvider picks the provider's cheapest plan that can run the
+// workload at the projected bandwidth
+func estimateProvider(provider DeploymentProvider, plans []hostingPlan, profile ProjectProfile, bandwidthGB float64) CostEstimate {
+	estimate := CostEstimate{
+		Provider:    provider,
+		Name:        getProviderDisplayName(provider),
+		BandwidthGB: bandwidthGB,
+	}
+
+	// A static site needs nothing running, so always-on only matters for
+	// apps that execute code
+	needsAwake := profile.AlwaysOn && profile.Workload != WorkloadStatic
+	var best *hostingPlan
+	bestCost := math.Inf(1)
+	served, awake := false, false
+	for i := range plans {
+		plan := plans[i]
+		if !plan.serves(profile.Workload) {
+			continue
+		}
+		served = true
+		if needsAwake && (plan.sleeps || plan.serverless) {
+			continue
+		}
+		awake = true
+		cost := plan.monthlyUSD
+		if over := bandwidthGB - plan.includedGB; over > 0 {
+			if plan.overagePerGB == 0 {
+				continue
+			}
+			cost += over * plan.overagePerGB
+		}
+		if cost < bestCost {
+			best, bestCost = &plan, cost
+		}
+	}
+
+	switch {
+	case !served && profile.Workload == WorkloadServer:
+		estimate.Reason = "Runs a long-lived server; this provider only hosts static sites and serverless functions"
+		return estimate
+	case !served:
+		estimate.Reason = "No plan serves this kind of app"
+		return estimate
+	case !awake:
+		estimate.Reason = "Needs an always-on process for " + strings.Join(profile.AlwaysOnReasons, ", ") +
+			"; this provider's plans sleep or run short-lived functions"
+		return estimate
+	case best == nil:
+		estimate.Reason = fmt.Sprintf("Projected bandwidth of %.0f GB exceeds every plan", bandwidthGB)
+		return estimate
+	}
+
+	estimate.Viable = true
+	estimate.Plan = best.name
+	estimate.MonthlyUSD = math.Round(bestCost*100) / 100
+	estimate.Notes = best.notes
+	return estimate
+}
@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func estimateFor(t *testing.T, est *Estimate, provider DeploymentProvider) CostEstimate {
+	t.Helper()
+	for _, e := range est.Providers {
+		if e.Provider == provider {
+			return e
+		}
+	}
+	t.Fatalf("no estimate for %s", provider)
+	return CostEstimate{}
+}
+
+func TestProfileProjectDetectsWorkloadAndAlwaysOn(t *testing.T) {
+	cases := []struct {
+		name     string
+		files    []ProjectFile
+		workload Workload
+		alwaysOn bool
+	}{
+		{
+			name:     "static site",
+			files:    []ProjectFile{{Path: "index.html", Content: "<html></html>"}},
+			workload: WorkloadStatic,
+		},
+		{
+			name:     "next.js app",
+			files:    []ProjectFile{{Path: "package.json", Content: `{"dependencies":{"next":"15.0.0","react":"19.0.0"}}`}},
+			workload: WorkloadServerRendered,
+		},
+		{
+			name:     "express with websockets",
+			files:    []ProjectFile{{Path: "/package.json", Content: `{"dependencies":{"express":"4.0.0","socket.io":"4.0.0"}}`}},
+			workload: WorkloadServer,
+			alwaysOn: true,
+		},
+		{
+			name:     "flask with celery",
+			files:    []ProjectFile{{Path: "requirements.txt", Content: "flask==3.0\ncelery>=5\n"}},
+			workload: WorkloadServer,
+			alwaysOn: true,
+		},
+	}
+	for _, tc := range cases {
+		profile := ProfileProject(tc.files)
+		if profile.Workload != tc.workload || profile.AlwaysOn != tc.alwaysOn {
+			t.Errorf("%s: profile = %+v", tc.name, profile)
+		}
+	}
+
+	profile := ProfileProject([]ProjectFile{
+		{Path: "index.html", Content: "12345"},
+		{Path: "node_modules/react/index.js", Content: strings.Repeat("x", 1000)},
+	})
+	if profile.BundleBytes != 5 || profile.FileCount != 1 {
+		t.Fatalf("bundle excluded dependencies wrongly: %+v", profile)
+	}
+}
+
+func TestEstimateCostsPicksCheapestViableTarget(t *testing.T) {
+	available := []DeploymentProvider{ProviderVercel, ProviderNetlify, ProviderRender}
+	native := EstimateOptions{NativePlanUSD: 24, NativePlanName: "Builder"}
+
+	static := EstimateCosts(ProjectProfile{Workload: WorkloadStatic, BundleBytes: 500_000}, native, available)
+	if static.Recommended != ProviderVercel || !estimateFor(t, static, ProviderRender).Viable {
+		t.Fatalf("static estimate = %+v", static)
+	}
+	if static.Providers[len(static.Providers)-1].Provider != ProviderNative {
+		t.Fatalf("paid native hosting should sort after free static hosts: %+v", static.Providers)
+	}
+
+	server := EstimateCosts(ProjectProfile{Workload: WorkloadServer, BundleBytes: 500_000}, native, available)
+	if estimateFor(t, server, ProviderVercel).Viable || estimateFor(t, server, ProviderNetlify).Viable {
+		t.Fatalf("serverless hosts cannot run a long-lived server: %+v", server.Providers)
+	}
+	if render := estimateFor(t, server, ProviderRender); render.Plan != "Free Web Service" || render.MonthlyUSD != 0 {
+		t.Fatalf("render = %+v", render)
+	}
+
+	alwaysOn := EstimateCosts(ProjectProfile{Workload: WorkloadServerRendered, BundleBytes: 500_000},
+		EstimateOptions{AlwaysOn: true, NativeIncluded: true}, available)
+	if render := estimateFor(t, alwaysOn, ProviderRender); render.Plan != "Starter Web Service" || render.MonthlyUSD != 7 {
+		t.Fatalf("always-on render = %+v", render)
+	}
+	if estimateFor(t, alwaysOn, ProviderVercel).Viable {
+		t.Fatal("serverless functions cannot stay always on")
+	}
+	if alwaysOn.Recommended != ProviderNative {
+		t.Fatalf("native is included in the plan and should win: %+v", alwaysOn)
+	}
+}
+
+func TestEstimateCostsChargesBandwidthOverage(t *testing.T) {
+	// 2 MB page weight x 1M views = ~2,097 GB
+	est := EstimateCosts(ProjectProfile{Workload: WorkloadStatic, BundleBytes: 10 << 20},
+		EstimateOptions{MonthlyPageViews: 1_000_000, NativeIncluded: true}, nil)
+	vercel := estimateFor(t, est, ProviderVercel)
+	if vercel.Plan != "Pro" || vercel.MonthlyUSD <= 20 {
+		t.Fatalf("vercel = %+v", vercel)
+	}
+	if est.Recommended != ProviderNative {
+		t.Fatalf("only native is available on this server: %+v", est)
+	}
+}
@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+
+	"apex-build/internal/buildhooks"
+)
+
+// PreDeployHooks runs the pre_deploy hooks a project declares in apex.yaml.
+// Implemented by *buildhooks.Runner.
+type PreDeployHooks interface {
+	Run(ctx context.Context, stage buildhooks.Stage, req buildhooks.Request, emit func(buildhooks.Event)) (*buildhooks.StageResult, error)
+}
+
+// SetPreDeployHooks runs the project's pre_deploy hooks before each build
+func (s *DeploymentService) SetPreDeployHooks(hooks PreDeployHooks) {
+	s.preDeployHooks = hooks
+}
+
+// runPreDeployHooks runs the pre_deploy hooks against the files being
+// deployed, logging their output in the "hooks" phase. It returns false
+// after failing the deployment when a hook blocked it.
+func (s *DeploymentService) runPreDeployHooks(ctx context.Context, deployment *Deployment, files []ProjectFile) bool {
+	if s.preDeployHooks == nil {
+		return true
+	}
+	req := buildhooks.Request{UserID: deployment.UserID, Files: make(map[string]string, len(files))}
+	for _, f := range files {
+		if !f.IsDir {
+			req.Files[f.Path] = f.Content
+		}
+	}
+	result, err := s.preDeployHooks.Run(ctx, buildhooks.StagePreDeploy, req, func(ev buildhooks.Event) {
+		if level, message := ev.LogLine(); message != "" {
+			s.addLog(deployment.ID, level, message, "hooks")
+		}
+	})
+	if err != nil {
+		if errors.Is(err, buildhooks.ErrInvalidConfig) {
+			s.failDeployment(deployment, err.Error())
+			return false
+		}
+		s.addLog(deployment.ID, "warn", "pre_deploy hooks skipped: "+err.Error(), "hooks")
+		return true
+	}
+	if msg := result.BlockError(); msg != "" {
+		s.failDeployment(deployment, msg)
+		return false
+	}
+	return true
+}
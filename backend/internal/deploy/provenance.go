@@ -0,0 +1,322 @@
+package deploy
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Provenance attestations follow the in-toto Statement v1 layout with an
+// SLSA v1 provenance predicate, wrapped in a DSSE envelope.
+const (
+	InTotoStatementType = "https://in-toto.io/Statement/v1"
+	SLSAProvenanceType  = "https://slsa.dev/provenance/v1"
+	DSSEPayloadType     = "application/vnd.in-toto+json"
+
+	provenanceBuildType = "https://apex.build/deploy/v1"
+	provenanceBuilderID = "https://apex.build/deploy"
+)
+
+// ErrProvenanceNotFound is returned for deployments that never built
+var ErrProvenanceNotFound = errors.New("provenance not found")
+
+// DeploymentProvenance is the attestation recorded when a deployment's
+// artifacts are packaged.
+type DeploymentProvenance struct {
+	ID           uint                `json:"id" gorm:"primarykey"`
+	DeploymentID string              `json:"deployment_id" gorm:"uniqueIndex;not null;type:varchar(36)"`
+	BuildID      string              `json:"source_build_id,omitempty" gorm:"index;size:64"`
+	Statement    ProvenanceStatement `json:"statement" gorm:"serializer:json;type:text"`
+	Envelope     DSSEEnvelope        `json:"envelope" gorm:"serializer:json;type:text"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// TableName keeps the table next to deployment_logs
+func (DeploymentProvenance) TableName() string { return "deployment_provenances" }
+
+// ProvenanceStatement is an in-toto statement about the deployed artifacts
+type ProvenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     SLSAProvenance       `json:"predicate"`
+}
+
+// ResourceDescriptor names an artifact or input by digest
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]any    `json:"annotations,omitempty"`
+}
+
+// SLSAProvenance is the SLSA v1 provenance predicate
+type SLSAProvenance struct {
+	BuildDefinition struct {
+		BuildType            string               `json:"buildType"`
+		ExternalParameters   map[string]any       `json:"externalParameters"`
+		InternalParameters   map[string]any       `json:"internalParameters,omitempty"`
+		ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			InvocationID string    `json:"invocationId"`
+			StartedOn    time.Time `json:"startedOn"`
+			FinishedOn   time.Time `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// AgentModel is one agent that contributed to the source build
+type AgentModel struct {
+	Role     string `json:"role"`
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+// DSSEEnvelope carries the statement and its signatures
+type DSSEEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"` // base64 statement JSON
+	Signatures  []DSSESignature `json:"signatures"`
+}
+
+// DSSESignature is an ed25519 signature over the envelope's PAE encoding
+type DSSESignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// SetProvenanceKey enables signing of provenance attestations. Without a
+// key attestations are still recorded, with no signatures.
+func (s *DeploymentService) SetProvenanceKey(key ed25519.PrivateKey) {
+	s.provenanceKey = key
+}
+
+// ProvenancePublicKey returns the verification key and its key ID, or
+// empty strings when attestations are unsigned.
+func (s *DeploymentService) ProvenancePublicKey() (publicKey, keyID string) {
+	if s.provenanceKey == nil {
+		return "", ""
+	}
+	pub := s.provenanceKey.Public().(ed25519.PublicKey)
+	return base64.StdEncoding.EncodeToString(pub), provenanceKeyID(pub)
+}
+
+// ParseProvenanceKey decodes a base64 ed25519 seed (32 bytes) or private
+// key (64 bytes), as stored in DEPLOY_PROVENANCE_SIGNING_KEY.
+func ParseProvenanceKey(encoded string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("provenance key is not base64: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("provenance key must be a %d-byte ed25519 seed or %d-byte private key", ed25519.SeedSize, ed25519.PrivateKeySize)
+}
+
+// GetProvenance returns the attestation recorded for a deployment
+func (s *DeploymentService) GetProvenance(deploymentID string) (*DeploymentProvenance, error) {
+	var provenance DeploymentProvenance
+	if err := s.db.Where("deployment_id = ?", deploymentID).First(&provenance).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProvenanceNotFound
+		}
+		return nil, err
+	}
+	return &provenance, nil
+}
+
+// VerifyProvenance checks the envelope signatures against the service key
+func (s *DeploymentService) VerifyProvenance(provenance *DeploymentProvenance) bool {
+	if s.provenanceKey == nil || provenance == nil {
+		return false
+	}
+	payload, err := base64.StdEncoding.DecodeString(provenance.Envelope.Payload)
+	if err != nil {
+		return false
+	}
+	pub := s.provenanceKey.Public().(ed25519.PublicKey)
+	keyID := provenanceKeyID(pub)
+	for _, signature := range provenance.Envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err == nil && signature.KeyID == keyID && ed25519.Verify(pub, dssePAE(provenance.Envelope.PayloadType, payload), sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordProvenance attests that the packaged artifacts were built from the
+// project source (and the AI build that produced it) with config.
+func (s *DeploymentService) recordProvenance(deployment *Deployment, config *DeploymentConfig, source, packaged []ProjectFile, startedOn, finishedOn time.Time) (*DeploymentProvenance, error) {
+	statement := ProvenanceStatement{
+		Type:          InTotoStatementType,
+		Subject:       artifactDigests(packaged),
+		PredicateType: SLSAProvenanceType,
+	}
+	predicate := &statement.Predicate
+	predicate.BuildDefinition.BuildType = provenanceBuildType
+	predicate.BuildDefinition.ExternalParameters = map[string]any{
+		"project_id":     deployment.ProjectID,
+		"provider":       string(config.Provider),
+		"environment":    config.Environment,
+		"branch":         config.Branch,
+		"build_command":  config.BuildCommand,
+		"install_cmd":    config.InstallCmd,
+		"output_dir":     config.OutputDir,
+		"root_directory": config.RootDirectory,
+	}
+
+	sourceDigest := map[string]string{"sha256": sourceDigest(source)}
+	if deployment.CommitSHA != "" {
+		sourceDigest["gitCommit"] = deployment.CommitSHA
+	}
+	predicate.BuildDefinition.ResolvedDependencies = []ResourceDescriptor{{
+		Name:   "source",
+		URI:    fmt.Sprintf("apex://projects/%d", deployment.ProjectID),
+		Digest: sourceDigest,
+	}}
+
+	buildID, agents := s.sourceBuild(deployment.ProjectID, config)
+	internal := map[string]any{"framework": config.Framework, "node_version": config.NodeVersion}
+	if buildID != "" {
+		internal["source_build_id"] = buildID
+		internal["agent_models"] = agents
+		predicate.BuildDefinition.ResolvedDependencies = append(predicate.BuildDefinition.ResolvedDependencies, ResourceDescriptor{
+			Name: "source_build",
+			URI:  "apex://builds/" + buildID,
+		})
+	}
+	predicate.BuildDefinition.InternalParameters = internal
+	predicate.RunDetails.Builder.ID = provenanceBuilderID
+	predicate.RunDetails.Metadata.InvocationID = deployment.ID
+	predicate.RunDetails.Metadata.StartedOn = startedOn.UTC()
+	predicate.RunDetails.Metadata.FinishedOn = finishedOn.UTC()
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+	provenance := &DeploymentProvenance{
+		DeploymentID: deployment.ID,
+		BuildID:      buildID,
+		Statement:    statement,
+		Envelope: DSSEEnvelope{
+			PayloadType: DSSEPayloadType,
+			Payload:     base64.StdEncoding.EncodeToString(payload),
+			Signatures:  []DSSESignature{},
+		},
+	}
+	if s.provenanceKey != nil {
+		pub := s.provenanceKey.Public().(ed25519.PublicKey)
+		provenance.Envelope.Signatures = append(provenance.Envelope.Signatures, DSSESignature{
+			KeyID: provenanceKeyID(pub),
+			Sig:   base64.StdEncoding.EncodeToString(ed25519.Sign(s.provenanceKey, dssePAE(DSSEPayloadType, payload))),
+		})
+	}
+	if err := s.db.Create(provenance).Error; err != nil {
+		return nil, err
+	}
+	return provenance, nil
+}
+
+// sourceBuild finds the AI build behind the deployed source: the build
+// named in custom.source_build_id, else the project's latest completed one.
+func (s *DeploymentService) sourceBuild(projectID uint, config *DeploymentConfig) (string, []AgentModel) {
+	var build struct {
+		BuildID    string
+		AgentsJSON string
+	}
+	query := s.db.Table("completed_builds").Select("build_id, agents_json").Where("deleted_at IS NULL")
+	if id, ok := config.Custom["source_build_id"].(string); ok && id != "" {
+		query = query.Where("build_id = ? AND project_id = ?", id, projectID)
+	} else {
+		query = query.Where("project_id = ? AND status = ?", projectID, "completed").Order("created_at DESC")
+	}
+	if err := query.Limit(1).Scan(&build).Error; err != nil {
+		log.Printf("deploy: failed to look up source build for project %d: %v", projectID, err)
+		return "", nil
+	}
+	if build.BuildID == "" {
+		return "", nil
+	}
+
+	var snapshots []AgentModel
+	_ = json.Unmarshal([]byte(build.AgentsJSON), &snapshots)
+	seen := make(map[AgentModel]bool)
+	agents := []AgentModel{}
+	for _, agent := range snapshots {
+		if agent.Provider == "" || seen[agent] {
+			continue
+		}
+		seen[agent] = true
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Role != agents[j].Role {
+			return agents[i].Role < agents[j].Role
+		}
+		return agents[i].Provider+agents[i].Model < agents[j].Provider+agents[j].Model
+	})
+	return build.BuildID, agents
+}
+
+func artifactDigests(files []ProjectFile) []ResourceDescriptor {
+	subjects := []ResourceDescriptor{}
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		sum := sha256.Sum256([]byte(file.Content))
+		subjects = append(subjects, ResourceDescriptor{
+			Name:   strings.TrimPrefix(file.Path, "/"),
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		})
+	}
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].Name < subjects[j].Name })
+	return subjects
+}
+
+// sourceDigest hashes the sorted path and content digest of every file, so
+// the same tree always yields the same digest.
+func sourceDigest(files []ProjectFile) string {
+	lines := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		sum := sha256.Sum256([]byte(file.Content))
+		lines = append(lines, strings.TrimPrefix(file.Path, "/")+"\x00"+hex.EncodeToString(sum[:])+"\n")
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// dssePAE is the DSSE pre-authentication encoding that gets signed
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+func provenanceKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRecordProvenanceAttestsArtifactsAndSourceBuild(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Deployment{}, &DeploymentProvenance{}, &models.CompletedBuild{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	projectID := uint(7)
+	agents := `[{"id":"a1","role":"frontend","provider":"claude","model":"claude-sonnet"},` +
+		`{"id":"a2","role":"backend","provider":"gpt","model":"gpt-5"},` +
+		`{"id":"a3","role":"frontend","provider":"claude","model":"claude-sonnet"}]`
+	if err := db.Create(&models.CompletedBuild{BuildID: "build-1", UserID: 1, ProjectID: &projectID, Status: "completed", AgentsJSON: agents}).Error; err != nil {
+		t.Fatalf("seed build: %v", err)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	key, err := ParseProvenanceKey(base64.StdEncoding.EncodeToString(seed))
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	svc := &DeploymentService{db: db}
+	svc.SetProvenanceKey(key)
+
+	deployment := &Deployment{ID: "dep-1", ProjectID: projectID, UserID: 1, Provider: ProviderVercel}
+	config := &DeploymentConfig{ProjectID: projectID, Provider: ProviderVercel, Environment: "production", BuildCommand: "npm run build"}
+	source := []ProjectFile{{Path: "src", IsDir: true}, {Path: "/src/index.js", Content: "console.log(1)"}}
+	packaged := []ProjectFile{{Path: "/index.js", Content: "console.log(1)"}, {Path: "/vercel.json", Content: "{}"}}
+	started := time.Now().Add(-time.Second)
+
+	if _, err := svc.recordProvenance(deployment, config, source, packaged, started, time.Now()); err != nil {
+		t.Fatalf("record provenance: %v", err)
+	}
+
+	provenance, err := svc.GetProvenance("dep-1")
+	if err != nil {
+		t.Fatalf("get provenance: %v", err)
+	}
+	if !svc.VerifyProvenance(provenance) {
+		t.Fatal("expected the envelope signature to verify")
+	}
+	if provenance.BuildID != "build-1" {
+		t.Fatalf("expected source build build-1, got %q", provenance.BuildID)
+	}
+
+	payload, _ := base64.StdEncoding.DecodeString(provenance.Envelope.Payload)
+	var statement ProvenanceStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if statement.Type != InTotoStatementType || statement.PredicateType != SLSAProvenanceType {
+		t.Fatalf("unexpected statement header: %s %s", statement.Type, statement.PredicateType)
+	}
+	if len(statement.Subject) != 2 || statement.Subject[0].Name != "index.js" || statement.Subject[0].Digest["sha256"] == "" {
+		t.Fatalf("unexpected subjects: %+v", statement.Subject)
+	}
+	if statement.Predicate.RunDetails.Metadata.InvocationID != "dep-1" {
+		t.Fatalf("expected invocation id dep-1, got %q", statement.Predicate.RunDetails.Metadata.InvocationID)
+	}
+	agentModels, _ := statement.Predicate.BuildDefinition.InternalParameters["agent_models"].([]any)
+	if len(agentModels) != 2 {
+		t.Fatalf("expected two distinct agent models, got %v", statement.Predicate.BuildDefinition.InternalParameters["agent_models"])
+	}
+
+	// Tampering with the payload breaks the signature.
+	provenance.Envelope.Payload = base64.StdEncoding.EncodeToString(append(payload, ' '))
+	if svc.VerifyProvenance(provenance) {
+		t.Fatal("expected a modified payload to fail verification")
+	}
+
+	if _, err := svc.GetProvenance("missing"); err != ErrProvenanceNotFound {
+		t.Fatalf("expected ErrProvenanceNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package deploy
+
+// StatusObserver is told about every deployment status change, for example
+// to add finished deployments to the project's activity feed.
+// Implementations must not block.
+type StatusObserver interface {
+	DeploymentStatusChanged(deployment *Deployment)
+}
+
+// SetStatusObserver reports deployment status changes to observer
+func (s *DeploymentService) SetStatusObserver(observer StatusObserver) {
+	s.statusObserver = observer
+}
+
+// notifyStatus hands a deployment's new status to the observer
+func (s *DeploymentService) notifyStatus(deployment *Deployment) {
+	if s.statusObserver != nil && deployment != nil {
+		s.statusObserver.DeploymentStatusChanged(deployment)
+	}
+}
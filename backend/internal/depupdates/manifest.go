@@ -0,0 +1,317 @@
+package depupdates
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/packages"
+	"apex-build/pkg/models"
+)
+
+// Ecosystems with manifests the agent can update. Go modules are left out:
+// bumping go.mod without regenerating go.sum breaks the build.
+const (
+	EcosystemNPM = string(packages.PackageTypeNPM)
+	EcosystemPip = string(packages.PackageTypePyPI)
+)
+
+// Update kinds, by the highest semver component that changed.
+const (
+	KindPatch = "patch"
+	KindMinor = "minor"
+	KindMajor = "major"
+)
+
+// Update is one dependency bump in a run.
+type Update struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+	Manifest  string `json:"manifest"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Kind      string `json:"kind"`
+	Dev       bool   `json:"dev,omitempty"`
+}
+
+// VersionSource looks up the latest published version of a package.
+type VersionSource interface {
+	LatestVersion(ctx context.Context, ecosystem, name string) (string, error)
+}
+
+// registrySource resolves versions through the package manager registries.
+type registrySource struct {
+	packages *packages.PackageManagerService
+}
+
+// NewRegistrySource returns a VersionSource backed by the npm and PyPI
+// registries.
+func NewRegistrySource(svc *packages.PackageManagerService) VersionSource {
+	return &registrySource{packages: svc}
+}
+
+func (r *registrySource) LatestVersion(ctx context.Context, ecosystem, name string) (string, error) {
+	info, err := r.packages.GetPackageInfo(name, packages.PackageType(ecosystem))
+	if err != nil {
+		return "", err
+	}
+	return info.LatestVersion, nil
+}
+
+// manifest is a dependency file found in the project.
+type manifest struct {
+	path      string
+	ecosystem string
+	content   string
+}
+
+// findManifests returns the project's package.json and requirements.txt
+// files, skipping installed dependencies.
+func findManifests(files []models.File) []manifest {
+	var found []manifest
+	for _, file := range files {
+		if file.Type == "directory" || file.IsBinary || strings.Contains(file.Path, "node_modules/") {
+			continue
+		}
+		switch path.Base(file.Path) {
+		case "package.json":
+			found = append(found, manifest{path: file.Path, ecosystem: EcosystemNPM, content: file.Content})
+		case "requirements.txt":
+			found = append(found, manifest{path: file.Path, ecosystem: EcosystemPip, content: file.Content})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].path < found[j].path })
+	return found
+}
+
+// npmSpec matches pinned, caret and tilde specs such as "^1.2.3".
+var npmSpec = regexp.MustCompile(`^([\^~]?)(\d+\.\d+\.\d+)$`)
+
+// pipPin matches "name==1.2.3" lines, with optional extras and comment.
+var pipPin = regexp.MustCompile(`^(\s*)([A-Za-z0-9][A-Za-z0-9._-]*(?:\[[^\]]*\])?)(\s*==\s*)([0-9][0-9A-Za-z.]*)(.*)$`)
+
+// candidate is a dependency whose current version the agent understands.
+type candidate struct {
+	name    string
+	current string
+	dev     bool
+	// rewrite returns the manifest with the dependency moved to version.
+	rewrite func(content, version string) (string, bool)
+}
+
+// npmCandidates lists package.json dependencies with a plain version spec.
+func npmCandidates(content string) ([]candidate, error) {
+	pkg, err := packages.ParsePackageJSON(content)
+	if err != nil {
+		return nil, err
+	}
+	var out []candidate
+	add := func(deps map[string]string, dev bool) {
+		for name, spec := range deps {
+			m := npmSpec.FindStringSubmatch(strings.TrimSpace(spec))
+			if m == nil {
+				continue
+			}
+			prefix, oldSpec := m[1], spec
+			key := regexp.MustCompile(`("` + regexp.QuoteMeta(name) + `"\s*:\s*)"` + regexp.QuoteMeta(oldSpec) + `"`)
+			out = append(out, candidate{
+				name:    name,
+				current: m[2],
+				dev:     dev,
+				rewrite: func(content, version string) (string, bool) {
+					loc := key.FindStringSubmatchIndex(content)
+					if loc == nil {
+						return content, false
+					}
+					return content[:loc[3]] + `"` + prefix + version + `"` + content[loc[1]:], true
+				},
+			})
+		}
+	}
+	add(pkg.Dependencies, false)
+	add(pkg.DevDependencies, true)
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out, nil
+}
+
+// pipCandidates lists requirements.txt lines pinned with ==.
+func pipCandidates(content string) []candidate {
+	var out []candidate
+	for _, line := range strings.Split(content, "\n") {
+		m := pipPin.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		name := m[2]
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		original := strings.TrimRight(line, "\r")
+		groups := m
+		out = append(out, candidate{
+			name:    name,
+			current: m[4],
+			rewrite: func(content, version string) (string, bool) {
+				replaced := groups[1] + groups[2] + groups[3] + version + groups[5]
+				lines := strings.Split(content, "\n")
+				for i, l := range lines {
+					if strings.TrimRight(l, "\r") == original {
+						lines[i] = strings.Replace(l, original, replaced, 1)
+						return strings.Join(lines, "\n"), true
+					}
+				}
+				return content, false
+			},
+		})
+	}
+	return out
+}
+
+// version is a parsed major.minor.patch release.
+type version struct {
+	major, minor, patch int
+}
+
+// parseVersion accepts releases with one to three numeric components.
+// Pre-releases and local versions are rejected so they are never proposed.
+func parseVersion(raw string) (version, bool) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(raw), "v"), ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return version{}, false
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		nums[i] = n
+	}
+	return version{nums[0], nums[1], nums[2]}, true
+}
+
+func (v version) less(o version) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
+
+// updateKind classifies the move from current to latest.
+func updateKind(current, latest version) string {
+	switch {
+	case latest.major != current.major:
+		return KindMajor
+	case latest.minor != current.minor:
+		return KindMinor
+	default:
+		return KindPatch
+	}
+}
+
+// planUpdates checks every understood dependency against the source and
+// returns the bumps allowed by the policy with the rewritten manifests.
+func planUpdates(ctx context.Context, source VersionSource, policy *Policy, manifests []manifest) ([]Update, map[string]string) {
+	ignored := make(map[string]bool, len(policy.IgnorePackages))
+	for _, name := range policy.IgnorePackages {
+		ignored[strings.ToLower(name)] = true
+	}
+
+	var updates []Update
+	changed := make(map[string]string)
+	for _, m := range manifests {
+		var candidates []candidate
+		switch m.ecosystem {
+		case EcosystemNPM:
+			list, err := npmCandidates(m.content)
+			if err != nil {
+				continue
+			}
+			candidates = list
+		case EcosystemPip:
+			candidates = pipCandidates(m.content)
+		}
+
+		content := m.content
+		for _, c := range candidates {
+			if len(updates) >= MaxUpdatesPerRun {
+				break
+			}
+			if ignored[strings.ToLower(c.name)] {
+				continue
+			}
+			current, ok := parseVersion(c.current)
+			if !ok {
+				continue
+			}
+			latestRaw, err := source.LatestVersion(ctx, m.ecosystem, c.name)
+			if err != nil {
+				continue
+			}
+			latest, ok := parseVersion(latestRaw)
+			if !ok || !current.less(latest) {
+				continue
+			}
+			kind := updateKind(current, latest)
+			if kind == KindMajor && !policy.IncludeMajor {
+				continue
+			}
+			rewritten, ok := c.rewrite(content, strings.TrimSpace(latestRaw))
+			if !ok {
+				continue
+			}
+			content = rewritten
+			updates = append(updates, Update{
+				Name:      c.name,
+				Ecosystem: m.ecosystem,
+				Manifest:  m.path,
+				From:      c.current,
+				To:        strings.TrimSpace(latestRaw),
+				Kind:      kind,
+				Dev:       c.dev,
+			})
+		}
+		if content != m.content {
+			changed[m.path] = content
+		}
+	}
+	return updates, changed
+}
+
+// defaultVerifyCommand installs the updated dependencies and runs the
+// project's tests (or build) in each manifest's directory.
+func defaultVerifyCommand(manifests []manifest, changed map[string]string) string {
+	var steps []string
+	for _, m := range manifests {
+		content, ok := changed[m.path]
+		if !ok {
+			continue
+		}
+		var step string
+		switch m.ecosystem {
+		case EcosystemNPM:
+			step = "npm install --no-audit --no-fund"
+			if pkg, err := packages.ParsePackageJSON(content); err == nil {
+				if test := pkg.Scripts["test"]; test != "" && !strings.Contains(test, "no test specified") {
+					step += " && npm test"
+				} else if pkg.Scripts["build"] != "" {
+					step += " && npm run build"
+				}
+			}
+		case EcosystemPip:
+			step = "pip install -r requirements.txt && python -m compileall -q ."
+		}
+		if dir := path.Dir(strings.TrimPrefix(m.path, "/")); dir != "." {
+			step = fmt.Sprintf("(cd %q && %s)", dir, step)
+		}
+		steps = append(steps, step)
+	}
+	return strings.Join(steps, " && ")
+}
@@ -0,0 +1,769 @@
+// Package depupdates is a scheduled dependency update agent. On each
+// project's cadence it checks package.json and requirements.txt for newer
+// releases, verifies the bumped manifests in an isolated sandbox workspace,
+// and then merges patch-only updates (when allowed), opens a pull request on
+// the connected repository, or leaves a change set for the owner to apply.
+package depupdates
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/execution"
+	"apex-build/internal/notifications"
+	"apex-build/internal/restorepoints"
+	"apex-build/internal/schedules"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTickInterval is how often the agent looks for due policies.
+const DefaultTickInterval = 15 * time.Minute
+
+const (
+	// MaxUpdatesPerRun bounds the bumps proposed in one run.
+	MaxUpdatesPerRun = 30
+	// RunHistoryLimit is how many runs are kept per project.
+	RunHistoryLimit = 50
+	// VerifyTimeout bounds the install and test run.
+	VerifyTimeout = 10 * time.Minute
+	// maxLogBytes caps the stored verification output.
+	maxLogBytes = 64 * 1024
+	// maxConcurrentRuns bounds agent runs across the instance.
+	maxConcurrentRuns = 4
+)
+
+// Cadences and the cron expression (UTC) each one runs on.
+const (
+	CadenceDaily   = "daily"
+	CadenceWeekly  = "weekly"
+	CadenceMonthly = "monthly"
+)
+
+var cadenceCron = map[string]string{
+	CadenceDaily:   "0 6 * * *",
+	CadenceWeekly:  "0 6 * * 1",
+	CadenceMonthly: "0 6 1 * *",
+}
+
+// Run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run statuses.
+const (
+	RunRunning       = "running"
+	RunNoUpdates     = "no_updates"
+	RunVerifyFailed  = "verify_failed"
+	RunQuotaExceeded = "quota_exceeded"
+	RunPROpened      = "pr_opened"
+	RunChangeSet     = "change_set" // verified, waiting to be applied
+	RunApplied       = "applied"
+	RunError         = "error"
+)
+
+var (
+	// ErrNotFound is returned for projects or runs the user cannot see.
+	ErrNotFound = errors.New("dependency update run not found")
+	// ErrInvalid wraps validation failures.
+	ErrInvalid = errors.New("invalid dependency update policy")
+	// ErrAlreadyRunning is returned when a manual run overlaps a running one.
+	ErrAlreadyRunning = errors.New("a dependency update run is already in progress")
+	// ErrNotApplicable is returned when applying a run without a pending change set.
+	ErrNotApplicable = errors.New("run has no change set to apply")
+	// ErrStale is returned when the manifests changed since the run.
+	ErrStale = errors.New("dependency files changed since this run; start a new run")
+)
+
+// Policy configures the agent for one project.
+type Policy struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ProjectID      uint       `gorm:"not null;uniqueIndex" json:"project_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Enabled        bool       `gorm:"not null;index" json:"enabled"`
+	Cadence        string     `gorm:"not null;size:16;default:weekly" json:"cadence"`
+	AutoMergePatch bool       `gorm:"not null" json:"auto_merge_patch"`
+	IncludeMajor   bool       `gorm:"not null" json:"include_major"`
+	OpenPR         bool       `gorm:"not null" json:"open_pr"`
+	IgnorePackages []string   `gorm:"serializer:json" json:"ignore_packages"`
+	VerifyCommand  string     `gorm:"type:text" json:"verify_command"`
+	NextRunAt      *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastStatus     string     `gorm:"size:20" json:"last_status,omitempty"`
+}
+
+// TableName keeps the table name explicit.
+func (Policy) TableName() string { return "dependency_update_policies" }
+
+// Run is one pass of the agent over a project.
+type Run struct {
+	ID             uint              `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	ProjectID      uint              `gorm:"not null;index" json:"project_id"`
+	UserID         uint              `gorm:"not null;index" json:"user_id"`
+	Trigger        string            `gorm:"not null;size:16" json:"trigger"`
+	Status         string            `gorm:"not null;size:20" json:"status"`
+	Updates        []Update          `gorm:"serializer:json" json:"updates"`
+	VerifyCommand  string            `gorm:"type:text" json:"verify_command,omitempty"`
+	ExecutionID    string            `gorm:"size:64" json:"execution_id,omitempty"`
+	VerifyExitCode int               `json:"verify_exit_code"`
+	VerifyOutput   string            `gorm:"type:text" json:"verify_output,omitempty"`
+	Files          map[string]string `gorm:"serializer:json" json:"files,omitempty"`
+	BaseHashes     map[string]string `gorm:"serializer:json" json:"-"`
+	Branch         string            `gorm:"size:255" json:"branch,omitempty"`
+	PullRequestURL string            `gorm:"size:500" json:"pull_request_url,omitempty"`
+	AutoMerged     bool              `json:"auto_merged"`
+	Error          string            `gorm:"type:text" json:"error,omitempty"`
+	StartedAt      time.Time         `json:"started_at"`
+	CompletedAt    *time.Time        `json:"completed_at,omitempty"`
+	AppliedAt      *time.Time        `json:"applied_at,omitempty"`
+}
+
+// TableName keeps the table name explicit.
+func (Run) TableName() string { return "dependency_update_runs" }
+
+// PatchOnly reports whether every update in the run is a patch release.
+func (r *Run) PatchOnly() bool {
+	for _, u := range r.Updates {
+		if u.Kind != KindPatch {
+			return false
+		}
+	}
+	return len(r.Updates) > 0
+}
+
+// Runner runs a command in a project workspace with some files replaced.
+// Implemented by *handlers.ExecutionHandler.
+type Runner interface {
+	RunProjectCommandWithFiles(ctx context.Context, projectID uint, command string, timeout time.Duration, overrides map[string]string) (*execution.ExecutionResult, error)
+}
+
+// Quota reserves execution minutes for verification runs.
+// Implemented by *usage.Tracker.
+type Quota interface {
+	ReserveExecution(ctx context.Context, userID uint, plan usage.PlanType, timeout time.Duration, enforce bool) (*usage.ExecutionReservation, error)
+	SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*usage.ExecutionReservation, error)
+	ReleaseExecution(ctx context.Context, reservationID string) error
+}
+
+// Publisher opens a pull request carrying files on a new branch and returns
+// its URL. It returns "" without an error when the project has no connected
+// repository or credentials. Implemented by *handlers.GitHandler.
+type Publisher interface {
+	OpenFilesPullRequest(ctx context.Context, userID, projectID uint, branch, title, body string, files map[string]string) (string, error)
+}
+
+// Notifier posts run results. Implemented by *notifications.Service.
+type Notifier interface {
+	Notify(ctx context.Context, n *notifications.Notification) error
+}
+
+// RestorePoints snapshots a project before updates are applied.
+// Implemented by *restorepoints.Service.
+type RestorePoints interface {
+	SnapshotProject(tx *gorm.DB, projectID, userID uint, reason, label string) error
+}
+
+// PolicyInput patches a policy; nil fields are left unchanged.
+type PolicyInput struct {
+	Enabled        *bool     `json:"enabled"`
+	Cadence        *string   `json:"cadence"`
+	AutoMergePatch *bool     `json:"auto_merge_patch"`
+	IncludeMajor   *bool     `json:"include_major"`
+	OpenPR         *bool     `json:"open_pr"`
+	IgnorePackages *[]string `json:"ignore_packages"`
+	VerifyCommand  *string   `json:"verify_command"`
+}
+
+// Service manages policies and runs the agent when due.
+type Service struct {
+	db        *gorm.DB
+	source    VersionSource
+	runner    Runner
+	quota     Quota
+	publisher Publisher
+	notifier  Notifier
+	points    RestorePoints
+
+	sem     chan struct{}
+	mu      sync.Mutex
+	running map[uint]bool
+	wg      sync.WaitGroup
+}
+
+// NewService creates a dependency update Service.
+func NewService(db *gorm.DB, source VersionSource) *Service {
+	return &Service{
+		db:      db,
+		source:  source,
+		sem:     make(chan struct{}, maxConcurrentRuns),
+		running: make(map[uint]bool),
+	}
+}
+
+// SetRunner wires the sandbox that verifies updates.
+func (s *Service) SetRunner(r Runner) { s.runner = r }
+
+// SetQuota wires execution-minute reservations for verification runs.
+func (s *Service) SetQuota(q Quota) { s.quota = q }
+
+// SetPublisher wires pull requests on the connected repository.
+func (s *Service) SetPublisher(p Publisher) { s.publisher = p }
+
+// SetNotifier wires result notifications.
+func (s *Service) SetNotifier(n Notifier) { s.notifier = n }
+
+// SetRestorePoints wires snapshots taken before updates are applied.
+func (s *Service) SetRestorePoints(p RestorePoints) { s.points = p }
+
+// AutoMigrate creates the dependency update tables.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Policy{}, &Run{})
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// apply validates input onto the policy and recomputes its next run.
+func (p *Policy) apply(in PolicyInput, now time.Time) error {
+	if in.Enabled != nil {
+		p.Enabled = *in.Enabled
+	}
+	if in.Cadence != nil {
+		p.Cadence = strings.ToLower(strings.TrimSpace(*in.Cadence))
+	}
+	if in.AutoMergePatch != nil {
+		p.AutoMergePatch = *in.AutoMergePatch
+	}
+	if in.IncludeMajor != nil {
+		p.IncludeMajor = *in.IncludeMajor
+	}
+	if in.OpenPR != nil {
+		p.OpenPR = *in.OpenPR
+	}
+	if in.IgnorePackages != nil {
+		ignored := []string{}
+		for _, name := range *in.IgnorePackages {
+			if name = strings.TrimSpace(name); name != "" {
+				ignored = append(ignored, name)
+			}
+		}
+		if len(ignored) > 200 {
+			return invalid("at most 200 ignored packages")
+		}
+		p.IgnorePackages = ignored
+	}
+	if in.VerifyCommand != nil {
+		p.VerifyCommand = strings.TrimSpace(*in.VerifyCommand)
+		if len(p.VerifyCommand) > 2000 {
+			return invalid("verify_command is too long (max 2000 characters)")
+		}
+	}
+
+	if p.Cadence == "" {
+		p.Cadence = CadenceWeekly
+	}
+	expr, ok := cadenceCron[p.Cadence]
+	if !ok {
+		return invalid("cadence must be daily, weekly or monthly")
+	}
+	if !p.Enabled {
+		p.NextRunAt = nil
+		return nil
+	}
+	schedule, err := schedules.ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(now.UTC())
+	p.NextRunAt = &next
+	return nil
+}
+
+// ownedProject checks that the user owns the project.
+func (s *Service) ownedProject(ctx context.Context, userID, projectID uint) error {
+	var project models.Project
+	if err := s.db.WithContext(ctx).Select("id").Where("id = ? AND owner_id = ?", projectID, userID).First(&project).Error; err != nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPolicy returns the project's policy, or the disabled default when none
+// has been saved.
+func (s *Service) GetPolicy(ctx context.Context, userID, projectID uint) (*Policy, error) {
+	if err := s.ownedProject(ctx, userID, projectID); err != nil {
+		return nil, err
+	}
+	var policy Policy
+	err := s.db.WithContext(ctx).Where("project_id = ?", projectID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Policy{ProjectID: projectID, UserID: userID, Cadence: CadenceWeekly, OpenPR: true, IgnorePackages: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// UpdatePolicy creates or patches the project's policy.
+func (s *Service) UpdatePolicy(ctx context.Context, userID, projectID uint, in PolicyInput) (*Policy, error) {
+	policy, err := s.GetPolicy(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.apply(in, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	policy.UserID = userID
+	if err := s.db.WithContext(ctx).Save(policy).Error; err != nil {
+		return nil, fmt.Errorf("depupdates: save policy failed: %w", err)
+	}
+	return policy, nil
+}
+
+// ListRuns returns the project's runs, newest first, without file contents
+// or logs.
+func (s *Service) ListRuns(ctx context.Context, userID, projectID uint, limit int) ([]Run, error) {
+	if err := s.ownedProject(ctx, userID, projectID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > RunHistoryLimit {
+		limit = RunHistoryLimit
+	}
+	runs := []Run{}
+	err := s.db.WithContext(ctx).
+		Omit("files", "verify_output").
+		Where("project_id = ?", projectID).
+		Order("id DESC").Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// GetRun returns one run with its change set and verification output.
+func (s *Service) GetRun(ctx context.Context, userID, projectID, runID uint) (*Run, error) {
+	if err := s.ownedProject(ctx, userID, projectID); err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := s.db.WithContext(ctx).Where("id = ? AND project_id = ?", runID, projectID).First(&run).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	return &run, nil
+}
+
+// RunNow starts the agent immediately, outside the cadence. The returned
+// run is in the running state; poll GetRun for the result.
+func (s *Service) RunNow(ctx context.Context, userID, projectID uint) (*Run, error) {
+	policy, err := s.GetPolicy(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.claimRunning(projectID) {
+		return nil, ErrAlreadyRunning
+	}
+	run, err := s.startRun(ctx, policy, TriggerManual)
+	if err != nil {
+		s.releaseRunning(projectID)
+		return nil, err
+	}
+	s.dispatch(*policy, run)
+	return run, nil
+}
+
+// Apply writes a verified change set into the project. The manifests must be
+// unchanged since the run, so a stale change set never overwrites edits.
+func (s *Service) Apply(ctx context.Context, userID, projectID, runID uint) (*Run, error) {
+	run, err := s.GetRun(ctx, userID, projectID, runID)
+	if err != nil {
+		return nil, err
+	}
+	if (run.Status != RunChangeSet && run.Status != RunPROpened) || len(run.Files) == 0 {
+		return nil, ErrNotApplicable
+	}
+	if err := s.applyFiles(ctx, run, userID); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// applyFiles writes the run's files after checking them against the base
+// hashes, then marks the run applied.
+func (s *Service) applyFiles(ctx context.Context, run *Run, userID uint) error {
+	if s.points != nil {
+		if err := s.points.SnapshotProject(s.db.WithContext(ctx), run.ProjectID, userID, restorepoints.ReasonBulk,
+			fmt.Sprintf("Before dependency updates (run #%d)", run.ID)); err != nil {
+			log.Printf("depupdates: failed to snapshot project %d: %v", run.ProjectID, err)
+		}
+	}
+	now := time.Now().UTC()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for path, content := range run.Files {
+			var file models.File
+			if err := tx.Where("project_id = ? AND path = ?", run.ProjectID, path).First(&file).Error; err != nil {
+				return ErrStale
+			}
+			if contentHash(file.Content) != run.BaseHashes[path] {
+				return ErrStale
+			}
+			if err := tx.Model(&models.File{}).Where("id = ?", file.ID).Updates(map[string]any{
+				"content":      content,
+				"size":         len(content),
+				"hash":         contentHash(content),
+				"version":      gorm.Expr("version + 1"),
+				"last_edit_by": userID,
+				"updated_at":   now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		run.Status = RunApplied
+		run.AppliedAt = &now
+		return tx.Model(&Run{}).Where("id = ?", run.ID).
+			Updates(map[string]any{"status": RunApplied, "applied_at": now, "auto_merged": run.AutoMerged}).Error
+	})
+	return err
+}
+
+// Start runs the agent until ctx is cancelled.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTickInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if started, err := s.RunDue(ctx, time.Now().UTC()); err != nil {
+					log.Printf("depupdates: tick failed: %v", err)
+				} else if started > 0 {
+					log.Printf("depupdates: started %d dependency update runs", started)
+				}
+			}
+		}
+	}()
+}
+
+// RunDue starts every enabled policy whose next run is at or before now,
+// claiming each with a compare-and-set on next_run_at.
+func (s *Service) RunDue(ctx context.Context, now time.Time) (int, error) {
+	var due []Policy
+	if err := s.db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at").Limit(100).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	started := 0
+	for i := range due {
+		policy := due[i]
+		claimedAt := *policy.NextRunAt
+		if err := policy.apply(PolicyInput{}, now); err != nil {
+			continue
+		}
+		claim := s.db.WithContext(ctx).Model(&Policy{}).
+			Where("id = ? AND next_run_at = ?", policy.ID, claimedAt).
+			Update("next_run_at", policy.NextRunAt)
+		if claim.Error != nil || claim.RowsAffected != 1 {
+			continue
+		}
+		if !s.claimRunning(policy.ProjectID) {
+			continue
+		}
+		run, err := s.startRun(ctx, &policy, TriggerSchedule)
+		if err != nil {
+			s.releaseRunning(policy.ProjectID)
+			log.Printf("depupdates: failed to start run for project %d: %v", policy.ProjectID, err)
+			continue
+		}
+		s.dispatch(policy, run)
+		started++
+	}
+	return started, nil
+}
+
+// Wait blocks until dispatched runs finish; used by tests and shutdown.
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Service) claimRunning(projectID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[projectID] {
+		return false
+	}
+	s.running[projectID] = true
+	return true
+}
+
+func (s *Service) releaseRunning(projectID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, projectID)
+}
+
+func (s *Service) startRun(ctx context.Context, policy *Policy, trigger string) (*Run, error) {
+	run := &Run{
+		ProjectID: policy.ProjectID,
+		UserID:    policy.UserID,
+		Trigger:   trigger,
+		Status:    RunRunning,
+		Updates:   []Update{},
+		StartedAt: time.Now().UTC(),
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (s *Service) dispatch(policy Policy, run *Run) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.releaseRunning(policy.ProjectID)
+		s.sem <- struct{}{}
+		defer func() { <-s.sem }()
+		s.execute(context.Background(), &policy, run)
+	}()
+}
+
+// execute checks for updates, verifies them and delivers the result.
+func (s *Service) execute(ctx context.Context, policy *Policy, run *Run) {
+	var files []models.File
+	if err := s.db.WithContext(ctx).Where("project_id = ?", policy.ProjectID).Find(&files).Error; err != nil {
+		s.finish(ctx, policy, run, RunError, "Could not load project files: "+err.Error())
+		return
+	}
+	manifests := findManifests(files)
+	if len(manifests) == 0 {
+		s.finish(ctx, policy, run, RunNoUpdates, "")
+		return
+	}
+	if s.source == nil {
+		s.finish(ctx, policy, run, RunError, "Package registries are currently unavailable")
+		return
+	}
+
+	updates, changed := planUpdates(ctx, s.source, policy, manifests)
+	if len(updates) == 0 {
+		s.finish(ctx, policy, run, RunNoUpdates, "")
+		return
+	}
+	run.Updates = updates
+	run.Files = changed
+	run.BaseHashes = make(map[string]string, len(changed))
+	for _, m := range manifests {
+		if _, ok := changed[m.path]; ok {
+			run.BaseHashes[m.path] = contentHash(m.content)
+		}
+	}
+
+	// Verify in a throwaway workspace with the bumped manifests overlaid.
+	if s.runner == nil {
+		s.finish(ctx, policy, run, RunError, "Code execution is currently unavailable to verify updates")
+		return
+	}
+	run.VerifyCommand = policy.VerifyCommand
+	if run.VerifyCommand == "" {
+		run.VerifyCommand = defaultVerifyCommand(manifests, changed)
+	}
+	reservationID := ""
+	if s.quota != nil {
+		plan, enforce := schedules.BillingFor(ctx, s.db, policy.UserID)
+		reservation, err := s.quota.ReserveExecution(ctx, policy.UserID, plan, VerifyTimeout, enforce)
+		if err != nil {
+			var quotaErr *usage.ExecutionQuotaError
+			if errors.As(err, &quotaErr) {
+				s.finish(ctx, policy, run, RunQuotaExceeded, "Skipped verification: "+quotaErr.Error())
+			} else {
+				s.finish(ctx, policy, run, RunError, "Could not reserve execution minutes: "+err.Error())
+			}
+			return
+		}
+		reservationID = reservation.ID
+	}
+	verifyCtx, cancel := context.WithTimeout(ctx, VerifyTimeout)
+	result, err := s.runner.RunProjectCommandWithFiles(verifyCtx, policy.ProjectID, run.VerifyCommand, VerifyTimeout, changed)
+	cancel()
+	if err != nil {
+		s.releaseReservation(ctx, reservationID)
+		s.finish(ctx, policy, run, RunError, "Verification could not run: "+err.Error())
+		return
+	}
+	if reservationID != "" {
+		projectID := policy.ProjectID
+		if _, err := s.quota.SettleExecution(ctx, reservationID, &projectID, result.DurationMs, result.CPUTime); err != nil {
+			log.Printf("depupdates: failed to settle execution for run %d: %v", run.ID, err)
+		}
+	}
+	run.ExecutionID = result.ID
+	run.VerifyExitCode = result.ExitCode
+	run.VerifyOutput = truncateLog(strings.TrimSpace(result.Output + "\n" + result.ErrorOutput))
+	if result.Status != "completed" || result.ExitCode != 0 {
+		s.finish(ctx, policy, run, RunVerifyFailed, "")
+		return
+	}
+
+	if policy.AutoMergePatch && run.PatchOnly() {
+		run.AutoMerged = true
+		if err := s.applyFiles(ctx, run, policy.UserID); err != nil {
+			run.AutoMerged = false
+			s.finish(ctx, policy, run, RunChangeSet, "Auto-merge failed: "+err.Error())
+			return
+		}
+		s.finish(ctx, policy, run, RunApplied, "")
+		return
+	}
+
+	if policy.OpenPR && s.publisher != nil {
+		run.Branch = fmt.Sprintf("apex/deps-%s", time.Now().UTC().Format("20060102-150405"))
+		url, err := s.publisher.OpenFilesPullRequest(ctx, policy.UserID, policy.ProjectID, run.Branch,
+			pullRequestTitle(run.Updates), pullRequestBody(run), changed)
+		switch {
+		case err != nil:
+			run.Branch = ""
+			s.finish(ctx, policy, run, RunChangeSet, "The pull request could not be opened: "+err.Error())
+			return
+		case url != "":
+			run.PullRequestURL = url
+			s.finish(ctx, policy, run, RunPROpened, "")
+			return
+		}
+		run.Branch = ""
+	}
+	s.finish(ctx, policy, run, RunChangeSet, "")
+}
+
+func (s *Service) releaseReservation(ctx context.Context, reservationID string) {
+	if reservationID == "" {
+		return
+	}
+	if err := s.quota.ReleaseExecution(ctx, reservationID); err != nil {
+		log.Printf("depupdates: failed to release reservation %s: %v", reservationID, err)
+	}
+}
+
+// finish stores the run outcome, updates the policy and notifies the owner
+// when there is something to act on.
+func (s *Service) finish(ctx context.Context, policy *Policy, run *Run, status, errorMessage string) {
+	now := time.Now().UTC()
+	run.Status = status
+	run.Error = errorMessage
+	run.CompletedAt = &now
+	if err := s.db.WithContext(ctx).Save(run).Error; err != nil {
+		log.Printf("depupdates: failed to save run %d: %v", run.ID, err)
+	}
+	if err := s.db.WithContext(ctx).Model(&Policy{}).Where("project_id = ?", policy.ProjectID).
+		Updates(map[string]any{"last_run_at": now, "last_status": status}).Error; err != nil {
+		log.Printf("depupdates: failed to update policy for project %d: %v", policy.ProjectID, err)
+	}
+
+	n := len(run.Updates)
+	switch status {
+	case RunApplied:
+		s.notify(ctx, run, notifications.SeverityInfo, fmt.Sprintf("%d patch updates applied", n),
+			"All updates were patch releases and passed verification, so they were merged automatically.")
+	case RunPROpened:
+		s.notify(ctx, run, notifications.SeverityInfo, fmt.Sprintf("%d dependency updates ready for review", n),
+			"Updates passed verification. Review the pull request: "+run.PullRequestURL)
+	case RunChangeSet:
+		s.notify(ctx, run, notifications.SeverityInfo, fmt.Sprintf("%d dependency updates ready to apply", n),
+			"Updates passed verification. Review and apply the change set from the project's dependency updates.")
+	case RunVerifyFailed:
+		s.notify(ctx, run, notifications.SeverityWarning, "Dependency updates failed verification",
+			fmt.Sprintf("%d updates were found but %q exited with code %d. Nothing was changed.", n, run.VerifyCommand, run.VerifyExitCode))
+	case RunQuotaExceeded:
+		s.notify(ctx, run, notifications.SeverityWarning, "Dependency updates were not verified", errorMessage)
+	case RunError:
+		s.notify(ctx, run, notifications.SeverityWarning, "Dependency update check failed", errorMessage)
+	}
+
+	s.pruneRuns(ctx, policy.ProjectID)
+}
+
+func (s *Service) notify(ctx context.Context, run *Run, severity, title, body string) {
+	if s.notifier == nil {
+		return
+	}
+	err := s.notifier.Notify(ctx, &notifications.Notification{
+		UserID:   run.UserID,
+		Kind:     "dependency_updates",
+		Severity: severity,
+		Title:    title,
+		Body:     body,
+		Metadata: map[string]any{
+			"project_id": run.ProjectID,
+			"run_id":     run.ID,
+			"status":     run.Status,
+		},
+	})
+	if err != nil {
+		log.Printf("depupdates: notify failed for run %d: %v", run.ID, err)
+	}
+}
+
+// pruneRuns keeps the newest RunHistoryLimit runs of a project.
+func (s *Service) pruneRuns(ctx context.Context, projectID uint) {
+	var cutoff Run
+	err := s.db.WithContext(ctx).Select("id").Where("project_id = ?", projectID).
+		Order("id DESC").Offset(RunHistoryLimit).Limit(1).First(&cutoff).Error
+	if err != nil {
+		return
+	}
+	s.db.WithContext(ctx).Where("project_id = ? AND id <= ?", projectID, cutoff.ID).Delete(&Run{})
+}
+
+func pullRequestTitle(updates []Update) string {
+	if len(updates) == 1 {
+		u := updates[0]
+		return fmt.Sprintf("Update %s to %s", u.Name, u.To)
+	}
+	return fmt.Sprintf("Update %d dependencies", len(updates))
+}
+
+func pullRequestBody(run *Run) string {
+	updates := append([]Update(nil), run.Updates...)
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Name < updates[j].Name })
+
+	var body strings.Builder
+	body.WriteString("| Package | From | To | Type | Manifest |\n|---|---|---|---|---|\n")
+	for _, u := range updates {
+		fmt.Fprintf(&body, "| `%s` | %s | %s | %s | `%s` |\n", u.Name, u.From, u.To, u.Kind, u.Manifest)
+	}
+	fmt.Fprintf(&body, "\nVerified with `%s` (exit code %d).\n\nOpened by the APEX.BUILD dependency update agent.", run.VerifyCommand, run.VerifyExitCode)
+	return body.String()
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncateLog(s string) string {
+	if len(s) <= maxLogBytes {
+		return s
+	}
+	return "[truncated: showing the last 64KB]\n" + s[len(s)-maxLogBytes:]
+}
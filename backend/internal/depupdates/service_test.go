@@ -0,0 +1,283 @@
+package depupdates
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"apex-build/internal/execution"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const testPackageJSON = `{
+  "name": "shop",
+  "scripts": {"test": "vitest run"},
+  "dependencies": {
+    "react": "^18.2.0",
+    "lodash": "4.17.20",
+    "left-pad": "latest"
+  },
+  "devDependencies": {
+    "vitest": "~1.0.1"
+  }
+}
+`
+
+type fakeSource map[string]string
+
+func (f fakeSource) LatestVersion(ctx context.Context, ecosystem, name string) (string, error) {
+	return f[ecosystem+":"+name], nil
+}
+
+type fakeRunner struct {
+	mu        sync.Mutex
+	command   string
+	overrides map[string]string
+	exitCode  int
+}
+
+func (f *fakeRunner) RunProjectCommandWithFiles(ctx context.Context, projectID uint, command string, timeout time.Duration, overrides map[string]string) (*execution.ExecutionResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.command = command
+	f.overrides = overrides
+	return &execution.ExecutionResult{ID: "exec-1", Status: "completed", ExitCode: f.exitCode, Output: "ok"}, nil
+}
+
+type fakeQuota struct {
+	exceeded bool
+	reserved []time.Duration
+	settled  []string
+}
+
+func (f *fakeQuota) ReserveExecution(ctx context.Context, userID uint, plan usage.PlanType, timeout time.Duration, enforce bool) (*usage.ExecutionReservation, error) {
+	if f.exceeded {
+		return nil, &usage.ExecutionQuotaError{Used: 60, Requested: 10, Limit: 60}
+	}
+	f.reserved = append(f.reserved, timeout)
+	return &usage.ExecutionReservation{ID: "res-1", UserID: userID}, nil
+}
+
+func (f *fakeQuota) SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*usage.ExecutionReservation, error) {
+	f.settled = append(f.settled, reservationID)
+	return &usage.ExecutionReservation{ID: reservationID}, nil
+}
+
+func (f *fakeQuota) ReleaseExecution(ctx context.Context, reservationID string) error { return nil }
+
+type fakePublisher struct {
+	url   string
+	files map[string]string
+}
+
+func (f *fakePublisher) OpenFilesPullRequest(ctx context.Context, userID, projectID uint, branch, title, body string, files map[string]string) (string, error) {
+	f.files = files
+	return f.url, nil
+}
+
+func TestPlanUpdatesRewritesManifests(t *testing.T) {
+	source := fakeSource{
+		"npm:react":    "19.0.0",
+		"npm:lodash":   "4.17.21",
+		"npm:vitest":   "1.2.0",
+		"pip:requests": "2.32.3",
+		"pip:flask":    "3.0.3",
+		"pip:django":   "5.1",
+	}
+	manifests := []manifest{
+		{path: "package.json", ecosystem: EcosystemNPM, content: testPackageJSON},
+		{path: "api/requirements.txt", ecosystem: EcosystemPip, content: "requests==2.31.0  # http\nflask[async]==3.0.0\ndjango>=4.2\n-r base.txt\n"},
+	}
+
+	updates, changed := planUpdates(context.Background(), source, &Policy{IgnorePackages: []string{"Flask"}}, manifests)
+	require.Len(t, updates, 3)
+	require.Equal(t, Update{Name: "lodash", Ecosystem: EcosystemNPM, Manifest: "package.json", From: "4.17.20", To: "4.17.21", Kind: KindPatch}, updates[0])
+	require.Equal(t, "vitest", updates[1].Name)
+	require.Equal(t, KindMinor, updates[1].Kind)
+	require.True(t, updates[1].Dev)
+	require.Equal(t, "requests", updates[2].Name)
+
+	// Specs keep their range prefix; majors and ignored packages are left alone.
+	require.Contains(t, changed["package.json"], `"lodash": "4.17.21"`)
+	require.Contains(t, changed["package.json"], `"vitest": "~1.2.0"`)
+	require.Contains(t, changed["package.json"], `"react": "^18.2.0"`)
+	require.Equal(t, "requests==2.32.3  # http\nflask[async]==3.0.0\ndjango>=4.2\n-r base.txt\n", changed["api/requirements.txt"])
+
+	updates, changed = planUpdates(context.Background(), source, &Policy{IncludeMajor: true}, manifests)
+	require.Len(t, updates, 5)
+	require.Contains(t, changed["package.json"], `"react": "^19.0.0"`)
+
+	require.Equal(t,
+		`npm install --no-audit --no-fund && npm test && (cd "api" && pip install -r requirements.txt && python -m compileall -q .)`,
+		defaultVerifyCommand(manifests, changed))
+}
+
+func setupDepUpdatesTest(t *testing.T, source fakeSource) (*Service, *gorm.DB, uint, uint, *fakeRunner) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}))
+	require.NoError(t, AutoMigrate(db))
+
+	name := strings.ToLower(t.Name())
+	user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	project := models.Project{Name: "shop", Language: "javascript", OwnerID: user.ID}
+	require.NoError(t, db.Create(&project).Error)
+	require.NoError(t, db.Create(&models.File{ProjectID: project.ID, Path: "package.json", Name: "package.json", Type: "file", Content: testPackageJSON}).Error)
+
+	runner := &fakeRunner{}
+	service := NewService(db, source)
+	service.SetRunner(runner)
+	return service, db, user.ID, project.ID, runner
+}
+
+func runAndWait(t *testing.T, service *Service, userID, projectID uint) *Run {
+	t.Helper()
+	run, err := service.RunNow(context.Background(), userID, projectID)
+	require.NoError(t, err)
+	service.Wait()
+	run, err = service.GetRun(context.Background(), userID, projectID, run.ID)
+	require.NoError(t, err)
+	return run
+}
+
+func packageJSON(t *testing.T, db *gorm.DB, projectID uint) string {
+	t.Helper()
+	var file models.File
+	require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "package.json").First(&file).Error)
+	return file.Content
+}
+
+func TestPatchUpdatesAutoMergeAfterVerification(t *testing.T) {
+	service, db, userID, projectID, runner := setupDepUpdatesTest(t, fakeSource{"npm:lodash": "4.17.21"})
+	ctx := context.Background()
+	_, err := service.UpdatePolicy(ctx, userID, projectID, PolicyInput{AutoMergePatch: boolPtr(true)})
+	require.NoError(t, err)
+
+	run := runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunApplied, run.Status)
+	require.True(t, run.AutoMerged)
+	require.Equal(t, "npm install --no-audit --no-fund && npm test", runner.command)
+	require.Contains(t, runner.overrides["package.json"], `"lodash": "4.17.21"`)
+	require.Contains(t, packageJSON(t, db, projectID), `"lodash": "4.17.21"`)
+
+	// Nothing left to update
+	run = runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunNoUpdates, run.Status)
+}
+
+func TestFailedVerificationChangesNothing(t *testing.T) {
+	service, db, userID, projectID, runner := setupDepUpdatesTest(t, fakeSource{"npm:lodash": "4.17.21"})
+	runner.exitCode = 1
+	_, err := service.UpdatePolicy(context.Background(), userID, projectID, PolicyInput{AutoMergePatch: boolPtr(true)})
+	require.NoError(t, err)
+
+	run := runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunVerifyFailed, run.Status)
+	require.Equal(t, 1, run.VerifyExitCode)
+	require.Equal(t, testPackageJSON, packageJSON(t, db, projectID))
+
+	_, err = service.Apply(context.Background(), userID, projectID, run.ID)
+	require.ErrorIs(t, err, ErrNotApplicable)
+}
+
+func TestVerificationReservesExecutionMinutes(t *testing.T) {
+	service, db, userID, projectID, runner := setupDepUpdatesTest(t, fakeSource{"npm:lodash": "4.17.21"})
+	quota := &fakeQuota{}
+	service.SetQuota(quota)
+
+	run := runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunChangeSet, run.Status)
+	require.Equal(t, []time.Duration{VerifyTimeout}, quota.reserved)
+	require.Equal(t, []string{"res-1"}, quota.settled)
+
+	// Out of minutes: the run is skipped before the sandbox is touched
+	quota.exceeded = true
+	runner.command = ""
+	run = runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunQuotaExceeded, run.Status)
+	require.Contains(t, run.Error, "Skipped verification")
+	require.Empty(t, runner.command)
+	require.Equal(t, testPackageJSON, packageJSON(t, db, projectID))
+}
+
+func TestMinorUpdatesOpenPullRequestOrChangeSet(t *testing.T) {
+	service, db, userID, projectID, _ := setupDepUpdatesTest(t, fakeSource{"npm:lodash": "4.17.21", "npm:vitest": "1.2.0"})
+	ctx := context.Background()
+	_, err := service.UpdatePolicy(ctx, userID, projectID, PolicyInput{AutoMergePatch: boolPtr(true)})
+	require.NoError(t, err)
+
+	publisher := &fakePublisher{url: "https://github.com/acme/shop/pull/7"}
+	service.SetPublisher(publisher)
+	run := runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunPROpened, run.Status, "a minor bump is never auto-merged")
+	require.Equal(t, "https://github.com/acme/shop/pull/7", run.PullRequestURL)
+	require.Contains(t, run.Branch, "apex/deps-")
+	require.Contains(t, publisher.files["package.json"], `"vitest": "~1.2.0"`)
+	require.Equal(t, testPackageJSON, packageJSON(t, db, projectID))
+
+	// Without a connected repository the result is kept as a change set
+	publisher.url = ""
+	run = runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunChangeSet, run.Status)
+	require.Empty(t, run.Branch)
+
+	applied, err := service.Apply(ctx, userID, projectID, run.ID)
+	require.NoError(t, err)
+	require.Equal(t, RunApplied, applied.Status)
+	require.Contains(t, packageJSON(t, db, projectID), `"vitest": "~1.2.0"`)
+	_, err = service.Apply(ctx, userID, projectID, run.ID)
+	require.ErrorIs(t, err, ErrNotApplicable)
+}
+
+func TestApplyRejectsStaleChangeSet(t *testing.T) {
+	service, db, userID, projectID, _ := setupDepUpdatesTest(t, fakeSource{"npm:vitest": "1.2.0"})
+	run := runAndWait(t, service, userID, projectID)
+	require.Equal(t, RunChangeSet, run.Status)
+
+	require.NoError(t, db.Model(&models.File{}).Where("project_id = ?", projectID).Update("content", testPackageJSON+"\n").Error)
+	_, err := service.Apply(context.Background(), userID, projectID, run.ID)
+	require.ErrorIs(t, err, ErrStale)
+}
+
+func TestPolicyValidationAndRunDue(t *testing.T) {
+	service, db, userID, projectID, _ := setupDepUpdatesTest(t, fakeSource{})
+	ctx := context.Background()
+
+	_, err := service.UpdatePolicy(ctx, userID, projectID, PolicyInput{Cadence: strPtr("hourly")})
+	require.ErrorIs(t, err, ErrInvalid)
+	_, err = service.UpdatePolicy(ctx, userID+1, projectID, PolicyInput{})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	policy, err := service.UpdatePolicy(ctx, userID, projectID, PolicyInput{Enabled: boolPtr(true)})
+	require.NoError(t, err)
+	require.Equal(t, CadenceWeekly, policy.Cadence)
+	require.NotNil(t, policy.NextRunAt)
+	require.Equal(t, time.Monday, policy.NextRunAt.Weekday())
+
+	now := policy.NextRunAt.Add(time.Minute)
+	started, err := service.RunDue(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, started)
+	service.Wait()
+
+	var stored Policy
+	require.NoError(t, db.Where("project_id = ?", projectID).First(&stored).Error)
+	require.True(t, stored.NextRunAt.After(now))
+	require.Equal(t, RunNoUpdates, stored.LastStatus)
+
+	started, err = service.RunDue(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, started)
+}
+
+func boolPtr(v bool) *bool    { return &v }
+func strPtr(v string) *string { return &v }
@@ -0,0 +1,56 @@
+package devcert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateReusesStoredCA(t *testing.T) {
+	dir := t.TempDir()
+	first, err := LoadOrCreate(dir)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, caKeyFile))
+	if err != nil {
+		t.Fatalf("stat key: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("key mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	second, err := LoadOrCreate(dir)
+	if err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+	if !bytes.Equal(first.CertPEM(), second.CertPEM()) {
+		t.Fatal("second load should reuse the stored CA")
+	}
+}
+
+func TestIssueCoversWildcardsAndIPs(t *testing.T) {
+	ca, err := LoadOrCreate(t.TempDir())
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, err := ca.Issue([]string{"localhost", "*.preview.localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca.CertPEM()) {
+		t.Fatal("CA PEM should parse")
+	}
+	for _, host := range []string{"localhost", "app-12.preview.localhost", "127.0.0.1"} {
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("verify %s: %v", host, err)
+		}
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err == nil {
+		t.Error("certificate should not cover unrelated hosts")
+	}
+}
@@ -0,0 +1,476 @@
+// Package dockerize generates production Dockerfiles for projects and
+// verifies them by building the image in the container sandbox. A verified
+// Dockerfile is stored in the project, where native hosting builds from it
+// instead of its own per-framework template.
+package dockerize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/deploy"
+)
+
+// ErrUnsupported is returned for projects whose stack can't be detected
+var ErrUnsupported = errors.New("could not detect a supported stack (Node.js, Python, Go, Rust or static HTML)")
+
+// Runtime versions used when a project doesn't pin its own
+const (
+	DefaultNodeVersion   = "22"
+	DefaultPythonVersion = "3.12"
+	DefaultGoVersion     = "1.23"
+)
+
+const nginxImage = "nginxinc/nginx-unprivileged:1.27-alpine"
+
+// Result is a generated Dockerfile and .dockerignore
+type Result struct {
+	Stack        string   `json:"stack"`
+	Port         int      `json:"port"`
+	Dockerfile   string   `json:"dockerfile"`
+	DockerIgnore string   `json:"dockerignore"`
+	Notes        []string `json:"notes,omitempty"`
+}
+
+// project is an indexed view of the files generation looks at
+type project struct {
+	files map[string]string
+	pkg   packageJSON
+}
+
+type packageJSON struct {
+	Name            string            `json:"name"`
+	Main            string            `json:"main"`
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Engines         struct {
+		Node string `json:"node"`
+	} `json:"engines"`
+}
+
+func (p *project) has(name string) bool {
+	_, ok := p.files[name]
+	return ok
+}
+
+func (p *project) dependsOn(dep string) bool {
+	_, runtime := p.pkg.Dependencies[dep]
+	_, dev := p.pkg.DevDependencies[dep]
+	return runtime || dev
+}
+
+// Generate writes a production Dockerfile and .dockerignore for the files.
+// Paths are relative to the project root, with or without a leading slash.
+func Generate(files []deploy.ProjectFile) (*Result, error) {
+	p := &project{files: make(map[string]string, len(files))}
+	normalized := make([]deploy.ProjectFile, 0, len(files))
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+f.Path), "/")
+		p.files[name] = f.Content
+		f.Path = "/" + name
+		normalized = append(normalized, f)
+	}
+	if content, ok := p.files["package.json"]; ok {
+		_ = json.Unmarshal([]byte(content), &p.pkg)
+	}
+
+	var result *Result
+	switch projectType := deploy.NewBuildService().DetectProjectType(normalized); projectType {
+	case deploy.ProjectTypeStaticHTML:
+		result = staticSite()
+	case deploy.ProjectTypeReact, deploy.ProjectTypeVue, deploy.ProjectTypeAngular:
+		result = spa(p, string(projectType))
+	case deploy.ProjectTypeSvelte:
+		if p.dependsOn("@sveltejs/kit") {
+			result = nodeServer(p, "sveltekit")
+		} else {
+			result = spa(p, "svelte")
+		}
+	case deploy.ProjectTypeNextJS, deploy.ProjectTypeNuxt, deploy.ProjectTypeNodeJS,
+		deploy.ProjectTypeExpress, deploy.ProjectTypeFastify:
+		result = nodeServer(p, string(projectType))
+	case deploy.ProjectTypePython, deploy.ProjectTypeFlask, deploy.ProjectTypeDjango, deploy.ProjectTypeFastAPI:
+		result = python(p, projectType)
+	case deploy.ProjectTypeGo:
+		result = goService(p)
+	case deploy.ProjectTypeRust:
+		result = rustService(p)
+	default:
+		return nil, ErrUnsupported
+	}
+	result.DockerIgnore = dockerIgnore(result.Stack, p)
+	return result, nil
+}
+
+// packageManager is how a Node project installs dependencies
+type packageManager struct {
+	manifests []string
+	install   string
+	run       string
+	prune     string
+}
+
+func (p *project) packageManager() packageManager {
+	switch {
+	case p.has("pnpm-lock.yaml"):
+		return packageManager{
+			manifests: []string{"package.json", "pnpm-lock.yaml"},
+			install:   "corepack enable && pnpm install --frozen-lockfile",
+			run:       "pnpm run",
+			prune:     "pnpm prune --prod",
+		}
+	case p.has("yarn.lock"):
+		return packageManager{
+			manifests: []string{"package.json", "yarn.lock"},
+			install:   "yarn install --frozen-lockfile",
+			run:       "yarn run",
+			prune:     "yarn install --frozen-lockfile --production --ignore-scripts --prefer-offline",
+		}
+	case p.has("package-lock.json"):
+		return packageManager{
+			manifests: []string{"package.json", "package-lock.json"},
+			install:   "npm ci",
+			run:       "npm run",
+			prune:     "npm prune --omit=dev",
+		}
+	}
+	return packageManager{
+		manifests: []string{"package.json"},
+		install:   "npm install",
+		run:       "npm run",
+		prune:     "npm prune --omit=dev",
+	}
+}
+
+var nodeMajorPattern = regexp.MustCompile(`\d+`)
+
+// nodeVersion honours engines.node when it names a current major
+func (p *project) nodeVersion() string {
+	if major := nodeMajorPattern.FindString(p.pkg.Engines.Node); major != "" && len(major) == 2 && major >= "18" {
+		return major
+	}
+	return DefaultNodeVersion
+}
+
+func nodeBuildStage(p *project, pm packageManager) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM node:%s-alpine AS build\n", p.nodeVersion())
+	b.WriteString("WORKDIR /app\n")
+	fmt.Fprintf(&b, "COPY %s ./\n", strings.Join(pm.manifests, " "))
+	fmt.Fprintf(&b, "RUN %s\n", pm.install)
+	b.WriteString("COPY . .\n")
+	if _, ok := p.pkg.Scripts["build"]; ok {
+		b.WriteString("ENV NODE_ENV=production\n")
+		fmt.Fprintf(&b, "RUN %s build\n", pm.run)
+	}
+	return b.String()
+}
+
+const spaNginxConfig = `RUN printf 'server {\n  listen 8080;\n  root /usr/share/nginx/html;\n  location / {\n    try_files $uri $uri/ /index.html;\n  }\n}\n' > /etc/nginx/conf.d/default.conf`
+
+// staticSite serves plain HTML with nginx
+func staticSite() *Result {
+	dockerfile := "FROM " + nginxImage + "\n" +
+		spaNginxConfig + "\n" +
+		"COPY . /usr/share/nginx/html\n" +
+		"EXPOSE 8080\n"
+	return &Result{Stack: "static", Port: 8080, Dockerfile: dockerfile}
+}
+
+// spa builds a client-side app and serves its output with nginx
+func spa(p *project, stack string) *Result {
+	result := &Result{Stack: stack, Port: 8080}
+	outputDir := "dist"
+	switch {
+	case p.dependsOn("react-scripts"):
+		outputDir = "build"
+	case stack == "angular":
+		outputDir = "dist/" + p.pkg.Name + "/browser"
+		result.Notes = append(result.Notes, "Angular output is assumed at "+outputDir+"; adjust the COPY if angular.json sets another outputPath")
+	}
+	if _, ok := p.pkg.Scripts["build"]; !ok {
+		result.Notes = append(result.Notes, "package.json has no build script; the image serves the source tree as-is")
+		outputDir = "."
+	}
+
+	var b strings.Builder
+	b.WriteString(nodeBuildStage(p, p.packageManager()))
+	b.WriteString("\nFROM " + nginxImage + "\n")
+	b.WriteString(spaNginxConfig + "\n")
+	fmt.Fprintf(&b, "COPY --from=build /app/%s /usr/share/nginx/html\n", outputDir)
+	b.WriteString("EXPOSE 8080\n")
+	result.Dockerfile = b.String()
+	return result
+}
+
+// nodeServer runs a Node.js server as the unprivileged node user
+func nodeServer(p *project, stack string) *Result {
+	result := &Result{Stack: stack, Port: 3000}
+	pm := p.packageManager()
+
+	var cmd string
+	switch _, hasStart := p.pkg.Scripts["start"]; {
+	case hasStart:
+		cmd = `["npm", "start"]`
+	case stack == "nuxt":
+		cmd = `["node", ".output/server/index.mjs"]`
+	case stack == "sveltekit":
+		cmd = `["node", "build"]`
+		result.Notes = append(result.Notes, "SvelteKit must use @sveltejs/adapter-node to run in a container")
+	default:
+		entry := p.pkg.Main
+		for _, candidate := range []string{"server.js", "index.js", "app.js", "src/index.js", "src/server.js"} {
+			if entry != "" {
+				break
+			}
+			if p.has(candidate) {
+				entry = candidate
+			}
+		}
+		if entry == "" {
+			entry = "index.js"
+			result.Notes = append(result.Notes, "No start script or entry file found; add a start script to package.json")
+		}
+		cmd = fmt.Sprintf(`["node", %q]`, entry)
+	}
+
+	var b strings.Builder
+	b.WriteString(nodeBuildStage(p, pm))
+	fmt.Fprintf(&b, "RUN %s\n", pm.prune)
+	fmt.Fprintf(&b, "\nFROM node:%s-alpine\n", p.nodeVersion())
+	b.WriteString("ENV NODE_ENV=production PORT=3000\n")
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("COPY --from=build --chown=node:node /app ./\n")
+	b.WriteString("USER node\n")
+	b.WriteString("EXPOSE 3000\n")
+	fmt.Fprintf(&b, "CMD %s\n", cmd)
+	result.Dockerfile = b.String()
+	return result
+}
+
+// pythonModule finds the module that defines the framework's app object
+func (p *project) pythonModule(marker string, candidates ...string) string {
+	for _, candidate := range candidates {
+		if strings.Contains(p.files[candidate], marker) {
+			return strings.ReplaceAll(strings.TrimSuffix(candidate, ".py"), "/", ".")
+		}
+	}
+	return ""
+}
+
+func (p *project) requires(pkg string) bool {
+	for _, line := range strings.Split(strings.ToLower(p.files["requirements.txt"]), "\n") {
+		name := strings.TrimSpace(line)
+		if i := strings.IndexAny(name, "=<>~![; "); i >= 0 {
+			name = name[:i]
+		}
+		if name == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// python runs a Python app with a production server where the framework
+// has one
+func python(p *project, projectType deploy.ProjectType) *Result {
+	result := &Result{Stack: string(projectType), Port: 8000}
+	candidates := []string{"main.py", "app.py", "app/main.py", "src/main.py", "wsgi.py", "server.py"}
+	var server, cmd string
+
+	switch projectType {
+	case deploy.ProjectTypeDjango:
+		server = "gunicorn"
+		wsgi := ""
+		names := make([]string, 0, len(p.files))
+		for name := range p.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if path.Base(name) == "wsgi.py" {
+				wsgi = strings.ReplaceAll(strings.TrimSuffix(name, ".py"), "/", ".")
+				break
+			}
+		}
+		if wsgi == "" {
+			wsgi = "config.wsgi"
+			result.Notes = append(result.Notes, "No wsgi.py found; assumed config.wsgi")
+		}
+		cmd = fmt.Sprintf(`["gunicorn", "--bind", "0.0.0.0:8000", "%s:application"]`, wsgi)
+		result.Notes = append(result.Notes, "Run collectstatic at build time once STATIC_ROOT is configured")
+	case deploy.ProjectTypeFastAPI:
+		server = "uvicorn"
+		module := p.pythonModule("FastAPI(", candidates...)
+		if module == "" {
+			module = "main"
+		}
+		cmd = fmt.Sprintf(`["uvicorn", "%s:app", "--host", "0.0.0.0", "--port", "8000"]`, module)
+	case deploy.ProjectTypeFlask:
+		server = "gunicorn"
+		module := p.pythonModule("Flask(", candidates...)
+		if module == "" {
+			module = "app"
+		}
+		cmd = fmt.Sprintf(`["gunicorn", "--bind", "0.0.0.0:8000", "%s:app"]`, module)
+	default:
+		entry := "main.py"
+		for _, candidate := range candidates {
+			if p.has(candidate) {
+				entry = candidate
+				break
+			}
+		}
+		cmd = fmt.Sprintf(`["python", %q]`, entry)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM python:%s-slim\n", DefaultPythonVersion)
+	b.WriteString("ENV PYTHONDONTWRITEBYTECODE=1 PYTHONUNBUFFERED=1 PORT=8000\n")
+	b.WriteString("WORKDIR /app\n")
+	extra := ""
+	if server != "" && !p.requires(server) {
+		extra = " " + server
+	}
+	switch {
+	case p.has("requirements.txt"):
+		b.WriteString("COPY requirements.txt ./\n")
+		fmt.Fprintf(&b, "RUN pip install --no-cache-dir -r requirements.txt%s\n", extra)
+		b.WriteString("COPY . .\n")
+	case p.has("Pipfile"):
+		b.WriteString("COPY Pipfile* ./\n")
+		b.WriteString("RUN pip install --no-cache-dir pipenv && pipenv install --system --deploy\n")
+		if extra != "" {
+			fmt.Fprintf(&b, "RUN pip install --no-cache-dir%s\n", extra)
+		}
+		b.WriteString("COPY . .\n")
+	default:
+		b.WriteString("COPY . .\n")
+		fmt.Fprintf(&b, "RUN pip install --no-cache-dir .%s\n", extra)
+	}
+	b.WriteString("RUN useradd --create-home --uid 10001 app\n")
+	b.WriteString("USER app\n")
+	b.WriteString("EXPOSE 8000\n")
+	fmt.Fprintf(&b, "CMD %s\n", cmd)
+	result.Dockerfile = b.String()
+	return result
+}
+
+var goDirectivePattern = regexp.MustCompile(`(?m)^go\s+1\.(\d+)`)
+
+// goService compiles a static binary onto a distroless base
+func goService(p *project) *Result {
+	result := &Result{Stack: "go", Port: 8080}
+	version := DefaultGoVersion
+	if m := goDirectivePattern.FindStringSubmatch(p.files["go.mod"]); m != nil {
+		if minor, err := strconv.Atoi(m[1]); err == nil && minor >= 22 {
+			version = "1." + m[1]
+		}
+	}
+
+	mainPkg := ""
+	var cmdDirs []string
+	for name, content := range p.files {
+		if !strings.HasSuffix(name, ".go") || !strings.Contains(content, "package main") {
+			continue
+		}
+		dir := path.Dir(name)
+		if dir == "." {
+			mainPkg = "."
+			break
+		}
+		cmdDirs = append(cmdDirs, dir)
+	}
+	if mainPkg == "" {
+		sort.Strings(cmdDirs)
+		if len(cmdDirs) > 0 {
+			mainPkg = "./" + cmdDirs[0]
+			if len(cmdDirs) > 1 {
+				result.Notes = append(result.Notes, "Several main packages found; building "+mainPkg)
+			}
+		} else {
+			mainPkg = "."
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM golang:%s-alpine AS build\n", version)
+	b.WriteString("WORKDIR /src\n")
+	b.WriteString("COPY go.mod go.sum* ./\n")
+	b.WriteString("RUN go mod download\n")
+	b.WriteString("COPY . .\n")
+	fmt.Fprintf(&b, "RUN CGO_ENABLED=0 go build -trimpath -ldflags=\"-s -w\" -o /out/app %s\n", mainPkg)
+	b.WriteString("\nFROM gcr.io/distroless/static-debian12:nonroot\n")
+	b.WriteString("COPY --from=build /out/app /app\n")
+	b.WriteString("ENV PORT=8080\n")
+	b.WriteString("USER nonroot:nonroot\n")
+	b.WriteString("EXPOSE 8080\n")
+	b.WriteString("ENTRYPOINT [\"/app\"]\n")
+	result.Dockerfile = b.String()
+	return result
+}
+
+var cargoNamePattern = regexp.MustCompile(`(?m)^\s*name\s*=\s*"([^"]+)"`)
+
+// rustService compiles a release binary onto a slim Debian base
+func rustService(p *project) *Result {
+	result := &Result{Stack: "rust", Port: 8080}
+	manifest := p.files["Cargo.toml"]
+	if i := strings.Index(manifest, "[package]"); i >= 0 {
+		manifest = manifest[i:]
+	}
+	binary := "app"
+	if m := cargoNamePattern.FindStringSubmatch(manifest); m != nil {
+		binary = m[1]
+	}
+	locked := ""
+	if p.has("Cargo.lock") {
+		locked = " --locked"
+	}
+
+	var b strings.Builder
+	b.WriteString("FROM rust:1-slim-bookworm AS build\n")
+	b.WriteString("WORKDIR /src\n")
+	b.WriteString("COPY . .\n")
+	fmt.Fprintf(&b, "RUN cargo build --release%s\n", locked)
+	b.WriteString("\nFROM debian:bookworm-slim\n")
+	b.WriteString("RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates \\\n")
+	b.WriteString("    && rm -rf /var/lib/apt/lists/* && useradd --uid 10001 app\n")
+	fmt.Fprintf(&b, "COPY --from=build /src/target/release/%s /usr/local/bin/app\n", binary)
+	b.WriteString("ENV PORT=8080\n")
+	b.WriteString("USER app\n")
+	b.WriteString("EXPOSE 8080\n")
+	b.WriteString("CMD [\"app\"]\n")
+	result.Dockerfile = b.String()
+	return result
+}
+
+// dockerIgnore keeps secrets, VCS data and host build output out of the
+// build context
+func dockerIgnore(stack string, p *project) string {
+	lines := []string{".git", ".gitignore", ".dockerignore", "Dockerfile", ".env", ".env.*", "*.log", ".DS_Store", ".vscode", ".idea"}
+	switch {
+	case p.has("package.json"):
+		lines = append(lines, "node_modules", "coverage", ".next", ".nuxt", ".output", ".svelte-kit")
+		if _, ok := p.pkg.Scripts["build"]; ok {
+			lines = append(lines, "dist", "build")
+		}
+	case stack == "go":
+		lines = append(lines, "bin")
+	case stack == "rust":
+		lines = append(lines, "target")
+	case strings.Contains(stack, "python") || stack == "flask" || stack == "django" || stack == "fastapi":
+		lines = append(lines, "__pycache__", "*.pyc", ".venv", "venv", ".pytest_cache", ".mypy_cache")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,358 @@
+package dockerize
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/deploy"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Paths the generated files are stored at, relative to the project root
+const (
+	DockerfilePath   = "Dockerfile"
+	DockerIgnorePath = ".dockerignore"
+)
+
+// maxBuildLog is how much of the end of the build output is kept
+const maxBuildLog = 16 << 10
+
+var (
+	ErrBuilderUnavailable = errors.New("image builds are unavailable: the container sandbox is not running")
+	ErrDockerfileExists   = errors.New("project already has a Dockerfile; pass overwrite to replace it")
+	ErrBuildFailed        = errors.New("the generated Dockerfile did not build")
+	ErrBuildInProgress    = errors.New("a Dockerfile build is already running for this project")
+	ErrNoFiles            = errors.New("project has no files")
+)
+
+// ImageBuilder builds images from a directory containing a Dockerfile.
+// execution.SandboxFactory implements it.
+type ImageBuilder interface {
+	BuildImage(ctx context.Context, contextDir, tag string) (string, error)
+	RemoveImage(ctx context.Context, tag string) error
+}
+
+// Options control a dockerize run
+type Options struct {
+	// Overwrite replaces a Dockerfile the project already has
+	Overwrite bool
+}
+
+// Outcome is a generated Dockerfile and what happened when it was built
+type Outcome struct {
+	Result
+	Verified   bool   `json:"verified"`
+	Saved      bool   `json:"saved"`
+	BuildLog   string `json:"build_log,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	// SkippedFiles were left out of the build context: binary files, whose
+	// bytes live in blob storage, and unsafe paths
+	SkippedFiles int `json:"skipped_files,omitempty"`
+}
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// Run is a Dockerize call running in the background. Image builds outlast
+// an HTTP request, so the API starts one and clients poll for the result.
+type Run struct {
+	ProjectID  uint       `json:"project_id"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Outcome    *Outcome   `json:"outcome,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Service generates, verifies and stores project Dockerfiles
+type Service struct {
+	db      *gorm.DB
+	builder ImageBuilder
+
+	mu       sync.Mutex
+	building map[uint]bool
+	runs     map[uint]*Run
+}
+
+// NewService creates a dockerize service. Without a builder Dockerfiles can
+// be previewed but not verified or stored.
+func NewService(db *gorm.DB, builder ImageBuilder) *Service {
+	return &Service{db: db, builder: builder, building: make(map[uint]bool), runs: make(map[uint]*Run)}
+}
+
+// Available reports whether Dockerfiles can be verified
+func (s *Service) Available() bool {
+	return s.builder != nil
+}
+
+func (s *Service) loadFiles(projectID uint) ([]models.File, error) {
+	var files []models.File
+	err := s.db.Select("id", "path", "name", "type", "content", "size", "is_binary", "version").
+		Where("project_id = ?", projectID).Find(&files).Error
+	return files, err
+}
+
+func toProjectFiles(files []models.File) []deploy.ProjectFile {
+	out := make([]deploy.ProjectFile, 0, len(files))
+	for _, f := range files {
+		out = append(out, deploy.ProjectFile{Path: f.Path, Content: f.Content, Size: f.Size, IsDir: f.Type == "directory"})
+	}
+	return out
+}
+
+func existingDockerfile(files []models.File) *models.File {
+	for i := range files {
+		if strings.TrimPrefix(files[i].Path, "/") == DockerfilePath {
+			return &files[i]
+		}
+	}
+	return nil
+}
+
+// Preview generates a Dockerfile without building or storing it
+func (s *Service) Preview(projectID uint) (*Result, error) {
+	files, err := s.loadFiles(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	return Generate(toProjectFiles(files))
+}
+
+// Dockerize generates a Dockerfile and .dockerignore, builds the image in
+// the sandbox and, when it builds, stores both files in the project. A
+// failed build returns the outcome with its log and ErrBuildFailed.
+func (s *Service) Dockerize(ctx context.Context, project *models.Project, userID uint, opts Options) (*Outcome, error) {
+	if s.builder == nil {
+		return nil, ErrBuilderUnavailable
+	}
+	files, err := s.loadFiles(project.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	if existingDockerfile(files) != nil && !opts.Overwrite {
+		return nil, ErrDockerfileExists
+	}
+	result, err := Generate(toProjectFiles(files))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.building[project.ID] {
+		s.mu.Unlock()
+		return nil, ErrBuildInProgress
+	}
+	s.building[project.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.building, project.ID)
+		s.mu.Unlock()
+	}()
+
+	outcome := &Outcome{Result: *result}
+	started := time.Now()
+	log, err := s.verify(ctx, project.ID, files, result, outcome)
+	outcome.DurationMs = time.Since(started).Milliseconds()
+	outcome.BuildLog = tail(log, maxBuildLog)
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Verified = true
+
+	if err := s.save(project.ID, userID, result); err != nil {
+		return outcome, err
+	}
+	outcome.Saved = true
+	return outcome, nil
+}
+
+// Start runs Dockerize in the background. Problems that don't need a build,
+// such as an existing Dockerfile or an unsupported stack, are returned
+// immediately.
+func (s *Service) Start(project *models.Project, userID uint, opts Options) (*Run, error) {
+	if s.builder == nil {
+		return nil, ErrBuilderUnavailable
+	}
+	files, err := s.loadFiles(project.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	if existingDockerfile(files) != nil && !opts.Overwrite {
+		return nil, ErrDockerfileExists
+	}
+	if _, err := Generate(toProjectFiles(files)); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if prev := s.runs[project.ID]; s.building[project.ID] || (prev != nil && prev.Status == RunRunning) {
+		s.mu.Unlock()
+		return nil, ErrBuildInProgress
+	}
+	run := &Run{ProjectID: project.ID, Status: RunRunning, StartedAt: time.Now()}
+	s.runs[project.ID] = run
+	snapshot := *run
+	s.mu.Unlock()
+
+	go func() {
+		outcome, err := s.Dockerize(context.Background(), project, userID, opts)
+		finished := time.Now()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		run.Outcome = outcome
+		run.FinishedAt = &finished
+		run.Status = RunSucceeded
+		if err != nil {
+			run.Status = RunFailed
+			run.Error = err.Error()
+		}
+	}()
+	return &snapshot, nil
+}
+
+// LatestRun returns the project's most recent background run since the
+// server started, or nil
+func (s *Service) LatestRun(projectID uint) *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[projectID]
+	if !ok {
+		return nil
+	}
+	snapshot := *run
+	return &snapshot
+}
+
+// verify writes the project into a temporary build context with the
+// generated files and builds it
+func (s *Service) verify(ctx context.Context, projectID uint, files []models.File, result *Result, outcome *Outcome) (string, error) {
+	dir, err := os.MkdirTemp("", "apex-dockerize-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, f := range files {
+		name := strings.TrimPrefix(path.Clean("/"+f.Path), "/")
+		if name == "" || name == DockerfilePath || name == DockerIgnorePath {
+			continue
+		}
+		if f.IsBinary {
+			outcome.SkippedFiles++
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			outcome.SkippedFiles++
+			continue
+		}
+		if f.Type == "directory" {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(target, []byte(f.Content), 0o644); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, DockerfilePath), []byte(result.Dockerfile), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, DockerIgnorePath), []byte(result.DockerIgnore), 0o644); err != nil {
+		return "", err
+	}
+
+	tag := fmt.Sprintf("apex-verify/project-%d:%s", projectID, randomSuffix())
+	log, err := s.builder.BuildImage(ctx, dir, tag)
+	// The image only proves the Dockerfile builds; hosting builds its own
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_ = s.builder.RemoveImage(cleanupCtx, tag)
+	if err != nil {
+		return log, fmt.Errorf("%w: %v", ErrBuildFailed, err)
+	}
+	return log, nil
+}
+
+// save stores the Dockerfile and .dockerignore as project files
+func (s *Service) save(projectID, userID uint, result *Result) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for filePath, content := range map[string]string{
+			DockerfilePath:   result.Dockerfile,
+			DockerIgnorePath: result.DockerIgnore,
+		} {
+			var file models.File
+			err := tx.Where("project_id = ? AND path IN ?", projectID, []string{filePath, "/" + filePath}).First(&file).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				file = models.File{
+					ProjectID:  projectID,
+					Path:       filePath,
+					Name:       filePath,
+					Type:       "file",
+					MimeType:   "text/plain",
+					Content:    content,
+					Size:       int64(len(content)),
+					LastEditBy: userID,
+				}
+				if err := tx.Create(&file).Error; err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			default:
+				if err := tx.Model(&file).Updates(map[string]interface{}{
+					"content":      content,
+					"size":         int64(len(content)),
+					"version":      file.Version + 1,
+					"last_edit_by": userID,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
@@ -0,0 +1,208 @@
+package dockerize
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/deploy"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeBuilder records the build context and fails when told to
+type fakeBuilder struct {
+	fail    bool
+	context map[string]string
+	removed []string
+}
+
+func (f *fakeBuilder) BuildImage(_ context.Context, dir, tag string) (string, error) {
+	f.context = make(map[string]string)
+	_ = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			data, _ := os.ReadFile(p)
+			f.context[filepath.ToSlash(rel)] = string(data)
+		}
+		return nil
+	})
+	if f.fail {
+		return "#5 ERROR: npm ci can only install with an existing package-lock.json", errors.New("exit status 1")
+	}
+	return "#7 naming to " + tag + " done", nil
+}
+
+func (f *fakeBuilder) RemoveImage(_ context.Context, tag string) error {
+	f.removed = append(f.removed, tag)
+	return nil
+}
+
+func TestGenerateDetectsStacks(t *testing.T) {
+	cases := []struct {
+		name  string
+		files []deploy.ProjectFile
+		stack string
+		want  []string
+	}{
+		{
+			name: "express with pnpm",
+			files: []deploy.ProjectFile{
+				{Path: "package.json", Content: `{"scripts":{"start":"node server.js"},"dependencies":{"express":"4"},"engines":{"node":">=20"}}`},
+				{Path: "pnpm-lock.yaml", Content: "lockfileVersion: 9"},
+			},
+			stack: "express",
+			want:  []string{"FROM node:20-alpine AS build", "pnpm install --frozen-lockfile", "pnpm prune --prod", "USER node", `CMD ["npm", "start"]`},
+		},
+		{
+			name: "vite react spa",
+			files: []deploy.ProjectFile{
+				{Path: "/package.json", Content: `{"scripts":{"build":"vite build"},"dependencies":{"react":"19"}}`},
+				{Path: "/package-lock.json", Content: "{}"},
+			},
+			stack: "react",
+			want:  []string{"RUN npm ci", "RUN npm run build", "COPY --from=build /app/dist /usr/share/nginx/html", "try_files"},
+		},
+		{
+			name: "fastapi in a package",
+			files: []deploy.ProjectFile{
+				{Path: "requirements.txt", Content: "fastapi==0.115\npydantic>=2\n"},
+				{Path: "app/main.py", Content: "from fastapi import FastAPI\napp = FastAPI()\n"},
+			},
+			stack: "fastapi",
+			want:  []string{"pip install --no-cache-dir -r requirements.txt uvicorn\n", `"app.main:app"`, "USER app"},
+		},
+		{
+			name: "go service under cmd",
+			files: []deploy.ProjectFile{
+				{Path: "go.mod", Content: "module example.com/api\n\ngo 1.24\n"},
+				{Path: "cmd/api/main.go", Content: "package main\n"},
+				{Path: "internal/store/store.go", Content: "package store\n"},
+			},
+			stack: "go",
+			want:  []string{"FROM golang:1.24-alpine AS build", "-o /out/app ./cmd/api", "distroless/static-debian12:nonroot"},
+		},
+	}
+	for _, tc := range cases {
+		result, err := Generate(tc.files)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if result.Stack != tc.stack {
+			t.Errorf("%s: stack = %s, want %s", tc.name, result.Stack, tc.stack)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(result.Dockerfile, want) {
+				t.Errorf("%s: Dockerfile missing %q:\n%s", tc.name, want, result.Dockerfile)
+			}
+		}
+		if !strings.Contains(result.DockerIgnore, ".env\n") {
+			t.Errorf("%s: .dockerignore must keep .env out of the image", tc.name)
+		}
+	}
+
+	if _, err := Generate([]deploy.ProjectFile{{Path: "notes.txt", Content: "hi"}}); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("unknown stack err = %v", err)
+	}
+}
+
+func setupDockerizeTest(t *testing.T, builder ImageBuilder) (*Service, *gorm.DB, *models.Project) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	user := &models.User{Username: "ada", Email: "ada@example.com", PasswordHash: "x"}
+	db.Create(user)
+	project := &models.Project{Name: "api", OwnerID: user.ID, Language: "javascript"}
+	db.Create(project)
+	for _, f := range []models.File{
+		{Path: "package.json", Name: "package.json", Type: "file", Content: `{"scripts":{"start":"node index.js"},"dependencies":{"express":"4"}}`},
+		{Path: "index.js", Name: "index.js", Type: "file", Content: "require('express')().listen(process.env.PORT)"},
+		{Path: "logo.png", Name: "logo.png", Type: "file", IsBinary: true},
+	} {
+		f.ProjectID = project.ID
+		db.Create(&f)
+	}
+	return NewService(db, builder), db, project
+}
+
+func TestDockerizeStoresOnlyVerifiedDockerfiles(t *testing.T) {
+	builder := &fakeBuilder{fail: true}
+	service, db, project := setupDockerizeTest(t, builder)
+
+	outcome, err := service.Dockerize(context.Background(), project, project.OwnerID, Options{})
+	if !errors.Is(err, ErrBuildFailed) || outcome == nil || outcome.Verified || outcome.Saved ||
+		!strings.Contains(outcome.BuildLog, "npm ci") {
+		t.Fatalf("failed build outcome = %+v, err = %v", outcome, err)
+	}
+	var count int64
+	db.Model(&models.File{}).Where("project_id = ? AND path = ?", project.ID, DockerfilePath).Count(&count)
+	if count != 0 {
+		t.Fatal("an unverified Dockerfile was stored")
+	}
+
+	builder.fail = false
+	outcome, err = service.Dockerize(context.Background(), project, project.OwnerID, Options{})
+	if err != nil || !outcome.Verified || !outcome.Saved || outcome.SkippedFiles != 1 {
+		t.Fatalf("outcome = %+v, err = %v", outcome, err)
+	}
+	if builder.context["index.js"] == "" || builder.context["Dockerfile"] != outcome.Dockerfile || len(builder.removed) != 2 {
+		t.Fatalf("build context = %v, removed = %v", builder.context, builder.removed)
+	}
+	var stored models.File
+	if err := db.Where("project_id = ? AND path = ?", project.ID, DockerfilePath).First(&stored).Error; err != nil || stored.Content != outcome.Dockerfile {
+		t.Fatalf("stored Dockerfile = %+v, err = %v", stored, err)
+	}
+
+	if _, err := service.Dockerize(context.Background(), project, project.OwnerID, Options{}); !errors.Is(err, ErrDockerfileExists) {
+		t.Fatalf("second run err = %v", err)
+	}
+	if _, err := service.Dockerize(context.Background(), project, project.OwnerID, Options{Overwrite: true}); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	db.Model(&models.File{}).Where("project_id = ? AND path = ?", project.ID, DockerfilePath).Count(&count)
+	if count != 1 {
+		t.Fatalf("overwrite left %d Dockerfiles", count)
+	}
+}
+
+func TestDockerizeWithoutBuilder(t *testing.T) {
+	service, _, project := setupDockerizeTest(t, nil)
+	if _, err := service.Dockerize(context.Background(), project, project.OwnerID, Options{}); !errors.Is(err, ErrBuilderUnavailable) {
+		t.Fatalf("err = %v", err)
+	}
+	if result, err := service.Preview(project.ID); err != nil || result.Stack != "express" {
+		t.Fatalf("preview = %+v, %v", result, err)
+	}
+}
+
+func TestStartRunsInBackground(t *testing.T) {
+	service, _, project := setupDockerizeTest(t, &fakeBuilder{})
+
+	run, err := service.Start(project, project.OwnerID, Options{})
+	if err != nil || run.Status != RunRunning {
+		t.Fatalf("start = %+v, %v", run, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for run.Status == RunRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		run = service.LatestRun(project.ID)
+	}
+	if run.Status != RunSucceeded || run.Outcome == nil || !run.Outcome.Saved || run.FinishedAt == nil {
+		t.Fatalf("run = %+v", run)
+	}
+	// Checks that need no build fail up front
+	if _, err := service.Start(project, project.OwnerID, Options{}); !errors.Is(err, ErrDockerfileExists) {
+		t.Fatalf("second start err = %v", err)
+	}
+}
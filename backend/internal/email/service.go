@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/smtp"
 	"os"
+	"time"
 )
 
 // Service handles sending transactional emails
@@ -130,6 +131,41 @@ func (s *Service) SendVerificationCode(to, username, code string) error {
 	return s.Send(to, subject, body)
 }
 
+// SendAccountDeletionCode sends the 6-digit code that confirms a permanent
+// account deletion request.
+func (s *Service) SendAccountDeletionCode(to, username, code string) error {
+	subject := "APEX-BUILD -- Confirm account deletion"
+	body := fmt.Sprintf(`<!DOCTYPE html>
+<html><body style="font-family: -apple-system, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+<h2 style="color: #ef4444;">Confirm account deletion</h2>
+<p>Hi %s,</p>
+<p>We received a request to permanently delete your APEX-BUILD account and all of its projects, builds, secrets, and deployments.</p>
+<p>Enter this code to confirm:</p>
+<p style="font-size: 32px; font-weight: 700; letter-spacing: 8px;">%s</p>
+<p>This code expires in 30 minutes. If you did not request this, change your password and ignore this email &mdash; nothing will be deleted.</p>
+<p>-- The APEX-BUILD Team</p>
+</body></html>`, username, code)
+
+	return s.Send(to, subject, body)
+}
+
+// SendProjectExportReady tells the user their project export can be
+// downloaded from account settings.
+func (s *Service) SendProjectExportReady(to, username string, projects int, expiresAt time.Time) error {
+	subject := "APEX-BUILD -- Your project export is ready"
+	body := fmt.Sprintf(`<!DOCTYPE html>
+<html><body style="font-family: -apple-system, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+<h2 style="color: #6366f1;">Your project export is ready</h2>
+<p>Hi %s,</p>
+<p>The backup of your %d project(s) has finished. Download it from your account settings:</p>
+<p><a href="https://apex-build.dev/settings" style="background: #6366f1; color: white; padding: 10px 20px; text-decoration: none; border-radius: 6px; display: inline-block;">Download Export</a></p>
+<p>The archive is available until %s. Environment variable names are included; their values are not.</p>
+<p>-- The APEX-BUILD Team</p>
+</body></html>`, username, projects, expiresAt.UTC().Format("January 2, 2006 15:04 MST"))
+
+	return s.Send(to, subject, body)
+}
+
 // IsEnabled returns whether the email service is configured
 func (s *Service) IsEnabled() bool {
 	return s.enabled
@@ -0,0 +1,184 @@
+// APEX.BUILD AI Region Policies
+// Organization data residency rules for AI provider routing
+
+package enterprise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"apex-build/internal/ai"
+
+	"gorm.io/gorm"
+)
+
+// aiRegionPolicyCacheTTL is how long a user's resolved regions are cached
+const aiRegionPolicyCacheTTL = 30 * time.Second
+
+var (
+	// ErrInvalidAIRegionPolicy wraps invalid policy settings
+	ErrInvalidAIRegionPolicy = errors.New("invalid AI region policy")
+	// ErrAIRegionPolicyConflict is returned when a user belongs to
+	// organizations whose policies share no region
+	ErrAIRegionPolicyConflict = errors.New("your organizations' AI region policies have no region in common")
+)
+
+// AIRegionPolicy limits the regions AI requests from an organization's
+// members may be processed in. Members of several organizations are held to
+// the regions all of their enabled policies allow.
+type AIRegionPolicy struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uint     `json:"organization_id" gorm:"not null;uniqueIndex"`
+	Enabled        bool     `json:"enabled" gorm:"default:false"`
+	AllowedRegions []string `json:"allowed_regions" gorm:"type:text;serializer:json"`
+
+	UpdatedBy *uint `json:"updated_by,omitempty"`
+}
+
+// TableName specifies the table name for AIRegionPolicy
+func (AIRegionPolicy) TableName() string {
+	return "organization_ai_region_policies"
+}
+
+type cachedAIRegions struct {
+	regions []ai.Region
+	expires time.Time
+}
+
+// AIRegionPolicyService stores organization AI region policies and resolves
+// them for the AI router
+type AIRegionPolicyService struct {
+	db    *gorm.DB
+	audit *AuditService
+
+	cache sync.Map // user ID -> *cachedAIRegions
+	now   func() time.Time
+}
+
+// NewAIRegionPolicyService creates an AI region policy service
+func NewAIRegionPolicyService(db *gorm.DB, audit *AuditService) *AIRegionPolicyService {
+	return &AIRegionPolicyService{db: db, audit: audit, now: time.Now}
+}
+
+// Get returns an organization's AI region policy, or a disabled policy when
+// none is configured
+func (s *AIRegionPolicyService) Get(orgID uint) (*AIRegionPolicy, error) {
+	var policy AIRegionPolicy
+	err := s.db.Where("organization_id = ?", orgID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &AIRegionPolicy{OrganizationID: orgID, AllowedRegions: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Save validates and stores an organization's AI region policy on behalf of
+// an administrator
+func (s *AIRegionPolicyService) Save(orgID, actorID uint, policy *AIRegionPolicy, clientIP, userAgent string) (*AIRegionPolicy, error) {
+	regions := make([]string, 0, len(policy.AllowedRegions))
+	for _, value := range policy.AllowedRegions {
+		region, ok := ai.ParseRegion(value)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown region %q", ErrInvalidAIRegionPolicy, value)
+		}
+		regions = append(regions, string(region))
+	}
+	policy.AllowedRegions = dedupeStrings(regions)
+	if policy.Enabled && len(policy.AllowedRegions) == 0 {
+		return nil, fmt.Errorf("%w: an enabled policy needs at least one allowed region", ErrInvalidAIRegionPolicy)
+	}
+
+	previous, err := s.Get(orgID)
+	if err != nil {
+		return nil, err
+	}
+	policy.ID = previous.ID
+	policy.CreatedAt = previous.CreatedAt
+	policy.OrganizationID = orgID
+	policy.UpdatedBy = &actorID
+	if err := s.db.Save(policy).Error; err != nil {
+		return nil, err
+	}
+	s.cache.Range(func(key, _ interface{}) bool {
+		s.cache.Delete(key)
+		return true
+	})
+
+	if s.audit != nil {
+		s.audit.LogEvent(&AuditLog{
+			OrganizationID: &orgID,
+			UserID:         &actorID,
+			IPAddress:      clientIP,
+			UserAgent:      userAgent,
+			Action:         "ai_region_policy_updated",
+			ResourceType:   "organization",
+			ResourceID:     strconv.FormatUint(uint64(orgID), 10),
+			Category:       "security",
+			Severity:       "warning",
+			Description:    "Organization AI region policy updated",
+			OldValue:       map[string]interface{}{"enabled": previous.Enabled, "allowed_regions": previous.AllowedRegions},
+			NewValue:       map[string]interface{}{"enabled": policy.Enabled, "allowed_regions": policy.AllowedRegions},
+		})
+	}
+	return policy, nil
+}
+
+// AllowedRegions implements ai.RegionPolicy: the regions every enabled
+// policy of the user's organizations allows, or nil when none applies.
+// Lookup failures are returned, not cached, so requests fail closed.
+func (s *AIRegionPolicyService) AllowedRegions(ctx context.Context, userID uint) ([]ai.Region, error) {
+	now := s.now()
+	if cached, ok := s.cache.Load(userID); ok {
+		entry := cached.(*cachedAIRegions)
+		if now.Before(entry.expires) {
+			return entry.regions, nil
+		}
+	}
+
+	var policies []AIRegionPolicy
+	err := s.db.WithContext(ctx).Table("organization_ai_region_policies AS p").
+		Select("p.*").
+		Joins("JOIN organization_members AS m ON m.organization_id = p.organization_id").
+		Joins("JOIN organizations AS o ON o.id = p.organization_id").
+		Where("m.user_id = ? AND m.status = ? AND m.deleted_at IS NULL AND o.deleted_at IS NULL AND p.enabled = ?", userID, "active", true).
+		Scan(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []ai.Region
+	for i, policy := range policies {
+		allowed := make([]ai.Region, 0, len(policy.AllowedRegions))
+		for _, value := range policy.AllowedRegions {
+			if region, ok := ai.ParseRegion(value); ok && (i == 0 || containsRegion(regions, region)) {
+				allowed = append(allowed, region)
+			}
+		}
+		regions = allowed
+		if len(regions) == 0 {
+			return nil, ErrAIRegionPolicyConflict
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i] < regions[j] })
+	s.cache.Store(userID, &cachedAIRegions{regions: regions, expires: now.Add(aiRegionPolicyCacheTTL)})
+	return regions, nil
+}
+
+func containsRegion(regions []ai.Region, region ai.Region) bool {
+	for _, r := range regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package enterprise
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"apex-build/internal/ai"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupAIRegionPolicyTest(t *testing.T) (*AIRegionPolicyService, *gorm.DB, *Organization, *Organization) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Organization{}, &Role{}, &OrganizationMember{}, &AuditLog{}, &AIRegionPolicy{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	acme := &Organization{Name: "Acme", Slug: "acme"}
+	globex := &Organization{Name: "Globex", Slug: "globex"}
+	for _, org := range []*Organization{acme, globex} {
+		if err := db.Create(org).Error; err != nil {
+			t.Fatalf("create org: %v", err)
+		}
+	}
+	// User 1 belongs to both organizations, user 2 only to Acme.
+	members := []OrganizationMember{
+		{OrganizationID: acme.ID, UserID: 1, RoleID: 1, Status: "active"},
+		{OrganizationID: globex.ID, UserID: 1, RoleID: 1, Status: "active"},
+		{OrganizationID: acme.ID, UserID: 2, RoleID: 1, Status: "active"},
+	}
+	for i := range members {
+		if err := db.Create(&members[i]).Error; err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+	}
+	return NewAIRegionPolicyService(db, NewAuditService(db)), db, acme, globex
+}
+
+func TestAIRegionPolicySaveValidates(t *testing.T) {
+	service, db, acme, _ := setupAIRegionPolicyTest(t)
+
+	if _, err := service.Save(acme.ID, 1, &AIRegionPolicy{Enabled: true, AllowedRegions: []string{"mars"}}, "", ""); !errors.Is(err, ErrInvalidAIRegionPolicy) {
+		t.Fatalf("unknown region err = %v", err)
+	}
+	if _, err := service.Save(acme.ID, 1, &AIRegionPolicy{Enabled: true}, "", ""); !errors.Is(err, ErrInvalidAIRegionPolicy) {
+		t.Fatalf("empty enabled policy err = %v", err)
+	}
+
+	saved, err := service.Save(acme.ID, 1, &AIRegionPolicy{Enabled: true, AllowedRegions: []string{"EU", "eu", "us"}}, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if len(saved.AllowedRegions) != 2 {
+		t.Fatalf("allowed regions = %v, want deduplicated eu, us", saved.AllowedRegions)
+	}
+
+	var audits int64
+	db.Model(&AuditLog{}).Where("action = ?", "ai_region_policy_updated").Count(&audits)
+	if audits != 1 {
+		t.Fatalf("audit entries = %d, want 1", audits)
+	}
+}
+
+func TestAIRegionPolicyAllowedRegionsIntersectsOrganizations(t *testing.T) {
+	service, _, acme, globex := setupAIRegionPolicyTest(t)
+	ctx := context.Background()
+
+	regions, err := service.AllowedRegions(ctx, 1)
+	if err != nil || regions != nil {
+		t.Fatalf("no policy = %v, %v; want unrestricted", regions, err)
+	}
+
+	if _, err := service.Save(acme.ID, 1, &AIRegionPolicy{Enabled: true, AllowedRegions: []string{"eu", "us"}}, "", ""); err != nil {
+		t.Fatalf("save acme: %v", err)
+	}
+	if _, err := service.Save(globex.ID, 1, &AIRegionPolicy{Enabled: true, AllowedRegions: []string{"eu"}}, "", ""); err != nil {
+		t.Fatalf("save globex: %v", err)
+	}
+
+	regions, err = service.AllowedRegions(ctx, 1)
+	if err != nil {
+		t.Fatalf("allowed regions: %v", err)
+	}
+	if len(regions) != 1 || regions[0] != ai.RegionEU {
+		t.Fatalf("user in both orgs = %v, want [eu]", regions)
+	}
+	regions, err = service.AllowedRegions(ctx, 2)
+	if err != nil || len(regions) != 2 {
+		t.Fatalf("acme-only user = %v, %v; want [eu us]", regions, err)
+	}
+
+	if _, err := service.Save(globex.ID, 1, &AIRegionPolicy{Enabled: true, AllowedRegions: []string{"us"}}, "", ""); err != nil {
+		t.Fatalf("save globex: %v", err)
+	}
+	if _, err := service.Save(acme.ID, 1, &AIRegionPolicy{Enabled: true, AllowedRegions: []string{"eu"}}, "", ""); err != nil {
+		t.Fatalf("save acme: %v", err)
+	}
+	if _, err := service.AllowedRegions(ctx, 1); !errors.Is(err, ErrAIRegionPolicyConflict) {
+		t.Fatalf("conflicting policies err = %v", err)
+	}
+
+	if _, err := service.Save(globex.ID, 1, &AIRegionPolicy{Enabled: false, AllowedRegions: []string{"us"}}, "", ""); err != nil {
+		t.Fatalf("disable globex: %v", err)
+	}
+	regions, err = service.AllowedRegions(ctx, 1)
+	if err != nil || len(regions) != 1 || regions[0] != ai.RegionEU {
+		t.Fatalf("after disabling globex = %v, %v; want [eu]", regions, err)
+	}
+}
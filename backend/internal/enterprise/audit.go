@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"time"
 
+	"apex-build/internal/pagination"
+
 	"gorm.io/gorm"
 )
 
 // AuditService handles enterprise audit logging
 type AuditService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	listeners []func(*AuditLog)
 }
 
 // NewAuditService creates a new audit service
@@ -39,9 +42,20 @@ func (s *AuditService) LogEvent(log *AuditLog) {
 
 	if err := s.db.Create(log).Error; err != nil {
 		fmt.Printf("Failed to write audit log: %v\n", err)
+		return
+	}
+	for _, listener := range s.listeners {
+		listener(log)
 	}
 }
 
+// AddListener calls fn with every audit event after it is stored. Listeners
+// run on the caller's goroutine and must not block. Register listeners
+// during startup, before events are logged.
+func (s *AuditService) AddListener(fn func(*AuditLog)) {
+	s.listeners = append(s.listeners, fn)
+}
+
 // GetAuditLogs retrieves audit logs with filtering
 func (s *AuditService) GetAuditLogs(orgID uint, action, category string, page, pageSize int) ([]AuditLog, int64, error) {
 	if page < 1 {
@@ -71,3 +85,46 @@ func (s *AuditService) GetAuditLogs(orgID uint, action, category string, page, p
 
 	return logs, total, nil
 }
+
+// AuditLogListSpec is the pagination spec for organization audit logs.
+var AuditLogListSpec = pagination.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "action", Column: "action"},
+		{Key: "category", Column: "category"},
+	},
+}
+
+// ListAuditLogs returns a page of an organization's audit logs and the total
+// matching the filters.
+func (s *AuditService) ListAuditLogs(orgID uint, action, category string, list *pagination.Request) ([]AuditLog, pagination.Info, int64, error) {
+	query := s.db.Model(&AuditLog{}).Where("organization_id = ?", orgID)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, pagination.Info{}, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	var logs []AuditLog
+	if err := list.Apply(query).Find(&logs).Error; err != nil {
+		return nil, pagination.Info{}, 0, fmt.Errorf("failed to fetch audit logs: %w", err)
+	}
+	logs, info := pagination.Page(list, logs, func(l AuditLog) (any, any) {
+		switch list.Sort.Key {
+		case "action":
+			return l.Action, l.ID
+		case "category":
+			return l.Category, l.ID
+		}
+		return l.CreatedAt, l.ID
+	})
+	return logs, info, total, nil
+}
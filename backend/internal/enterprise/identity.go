@@ -0,0 +1,768 @@
+// APEX.BUILD Identity Linking
+// Multiple sign-in identities per user, SSO account matching and account merges
+
+package enterprise
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/payments"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Identity providers
+const (
+	IdentityProviderSAML = "saml"
+	IdentityProviderOIDC = "oidc"
+)
+
+// How an identity came to be linked to its user
+const (
+	IdentityLinkedAtLogin    = "login"       // first sign-in created the user
+	IdentityLinkedEmailMatch = "email_match" // matched an existing verified email
+	IdentityLinkedMerge      = "merge"       // moved over in an account merge
+)
+
+// Outcomes of resolving an external identity
+const (
+	ResolveExisting = "existing"
+	ResolveLinked   = "linked"
+	ResolveCreated  = "created"
+)
+
+// Bulk migration actions
+const (
+	MigrationActionMerge       = "merge"
+	MigrationActionLinkOnLogin = "link_on_login"
+	MigrationActionSkip        = "skip"
+)
+
+// CreditEntryTypeAccountMerge marks credits moved between merged accounts
+const CreditEntryTypeAccountMerge = "account_merge"
+
+var (
+	// ErrInvalidIdentity wraps an external identity missing required claims
+	ErrInvalidIdentity = errors.New("invalid external identity")
+	// ErrUnverifiedEmail is returned when an SSO email matches an account
+	// whose email was never verified; linking it would let the IdP take
+	// over an account nobody proved they own
+	ErrUnverifiedEmail = errors.New("an account with this email exists but its email is not verified")
+	// ErrIdentityDisabled is returned when the matched account is deactivated
+	ErrIdentityDisabled = errors.New("account is disabled")
+	// ErrLastIdentity is returned when unlinking would leave an account
+	// with no way to sign in
+	ErrLastIdentity = errors.New("cannot unlink the only sign-in method")
+	// ErrInvalidMerge wraps merges between the wrong accounts
+	ErrInvalidMerge = errors.New("invalid account merge")
+	// ErrMergeBlocked is returned when a merge plan has blockers
+	ErrMergeBlocked = errors.New("account merge is blocked")
+)
+
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// UserIdentity is an external sign-in identity linked to a user. A user can
+// have any number of them alongside a password.
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID         uint   `json:"user_id" gorm:"not null;index"`
+	Provider       string `json:"provider" gorm:"size:16;not null;uniqueIndex:idx_user_identity_subject"`
+	Issuer         string `json:"issuer" gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Subject        string `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	OrganizationID *uint  `json:"organization_id,omitempty" gorm:"index"`
+	Email          string `json:"email"`
+	LinkedBy       string `json:"linked_by" gorm:"size:16"`
+
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// TableName specifies the table name for UserIdentity
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// ExternalIdentity is what an identity provider asserted about a user
+type ExternalIdentity struct {
+	Provider       string
+	Issuer         string
+	Subject        string
+	Email          string
+	FullName       string
+	OrganizationID *uint
+	// EmailVerified is set when the provider asserts it verified the email
+	EmailVerified bool
+}
+
+// MergePlan describes what merging one account into another moves
+type MergePlan struct {
+	SourceUserID     uint     `json:"source_user_id"`
+	SourceEmail      string   `json:"source_email"`
+	TargetUserID     uint     `json:"target_user_id"`
+	TargetEmail      string   `json:"target_email"`
+	Projects         int64    `json:"projects"`
+	Identities       int64    `json:"identities"`
+	Memberships      int64    `json:"memberships"`
+	CreditBalanceUSD float64  `json:"credit_balance_usd"`
+	MoveSubscription bool     `json:"move_subscription"`
+	Blockers         []string `json:"blockers,omitempty"`
+}
+
+// AccountMerge records a completed merge
+type AccountMerge struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time `json:"created_at"`
+	SourceUserID   uint      `json:"source_user_id" gorm:"not null;index"`
+	TargetUserID   uint      `json:"target_user_id" gorm:"not null;index"`
+	OrganizationID *uint     `json:"organization_id,omitempty" gorm:"index"`
+	ActorID        *uint     `json:"actor_id,omitempty"`
+	Plan           MergePlan `json:"plan" gorm:"type:text;serializer:json"`
+}
+
+// TableName specifies the table name for AccountMerge
+func (AccountMerge) TableName() string {
+	return "account_merges"
+}
+
+// MigrationItem is one email address in a bulk migration report
+type MigrationItem struct {
+	Email        string      `json:"email"`
+	Action       string      `json:"action"`
+	TargetUserID uint        `json:"target_user_id,omitempty"`
+	Merges       []MergePlan `json:"merges,omitempty"`
+	Reason       string      `json:"reason,omitempty"`
+	Applied      bool        `json:"applied"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// MigrationReport is the result of a bulk identity migration, or what it
+// would do when DryRun is set
+type MigrationReport struct {
+	OrganizationID uint            `json:"organization_id"`
+	DryRun         bool            `json:"dry_run"`
+	Members        int             `json:"members"`
+	Merges         int             `json:"merges"`
+	LinkOnLogin    int             `json:"link_on_login"`
+	Skipped        int             `json:"skipped"`
+	Failed         int             `json:"failed"`
+	Items          []MigrationItem `json:"items"`
+}
+
+// IdentityService links external identities to users and merges duplicate
+// accounts
+type IdentityService struct {
+	db        *gorm.DB
+	audit     *AuditService
+	lookupTXT func(name string) ([]string, error)
+}
+
+// NewIdentityService creates a new identity service
+func NewIdentityService(db *gorm.DB, audit *AuditService) *IdentityService {
+	return &IdentityService{db: db, audit: audit, lookupTXT: net.LookupTXT}
+}
+
+// trustsEmail reports whether the identity provider may vouch for the
+// email: it asserted the address is verified and the address is in a
+// domain its organization proved it owns
+func (s *IdentityService) trustsEmail(ext *ExternalIdentity) bool {
+	return ext.EmailVerified && ext.OrganizationID != nil && s.IsVerifiedDomain(*ext.OrganizationID, ext.Email)
+}
+
+// normalizeEmail lowercases and trims an email for matching
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Resolve finds or creates the user for an external identity. A known
+// identity signs in its user. An email matching an existing account is
+// only linked automatically when the provider is trusted for it (see
+// trustsEmail); otherwise Resolve returns a LinkRequiredError and the
+// account's owner must sign in and accept the link. Only when no account
+// has the email is a new user created.
+func (s *IdentityService) Resolve(ext *ExternalIdentity) (*models.User, string, error) {
+	ext.Provider = strings.TrimSpace(ext.Provider)
+	ext.Issuer = strings.TrimSpace(ext.Issuer)
+	ext.Subject = strings.TrimSpace(ext.Subject)
+	ext.Email = normalizeEmail(ext.Email)
+	if ext.Provider == "" || ext.Issuer == "" || ext.Subject == "" {
+		return nil, "", fmt.Errorf("%w: provider, issuer and subject are required", ErrInvalidIdentity)
+	}
+
+	now := time.Now()
+	var identity UserIdentity
+	err := s.db.Where("provider = ? AND issuer = ? AND subject = ?", ext.Provider, ext.Issuer, ext.Subject).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := s.db.First(&user, identity.UserID).Error; err != nil {
+			return nil, "", err
+		}
+		if !user.IsActive {
+			return nil, "", ErrIdentityDisabled
+		}
+		updates := map[string]interface{}{"last_used_at": now}
+		if ext.Email != "" {
+			updates["email"] = ext.Email
+		}
+		s.db.Model(&identity).Updates(updates)
+		return &user, ResolveExisting, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+	if ext.Email == "" {
+		return nil, "", fmt.Errorf("%w: the identity provider did not send an email address", ErrInvalidIdentity)
+	}
+
+	var user models.User
+	outcome := ResolveLinked
+	linkedBy := IdentityLinkedEmailMatch
+	err = s.db.Where("LOWER(email) = ?", ext.Email).Order("created_at ASC").First(&user).Error
+	switch {
+	case err == nil:
+		if !user.IsVerified {
+			return nil, "", ErrUnverifiedEmail
+		}
+		if !user.IsActive {
+			return nil, "", ErrIdentityDisabled
+		}
+		if !s.trustsEmail(ext) {
+			return nil, "", s.requestLink(ext)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		created, err := s.createUser(ext, s.trustsEmail(ext))
+		if err != nil {
+			return nil, "", err
+		}
+		user = *created
+		outcome = ResolveCreated
+		linkedBy = IdentityLinkedAtLogin
+	default:
+		return nil, "", err
+	}
+
+	identity = UserIdentity{
+		UserID:         user.ID,
+		Provider:       ext.Provider,
+		Issuer:         ext.Issuer,
+		Subject:        ext.Subject,
+		OrganizationID: ext.OrganizationID,
+		Email:          ext.Email,
+		LinkedBy:       linkedBy,
+		LastUsedAt:     &now,
+	}
+	// A concurrent sign-in may have linked the same identity first
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&identity)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return s.Resolve(ext)
+	}
+
+	if outcome == ResolveLinked && s.audit != nil {
+		userID := user.ID
+		s.audit.LogEvent(&AuditLog{
+			OrganizationID: ext.OrganizationID,
+			UserID:         &userID,
+			Username:       user.Username,
+			Email:          user.Email,
+			Action:         "identity_linked",
+			ResourceType:   "user",
+			ResourceID:     strconv.FormatUint(uint64(user.ID), 10),
+			Category:       "authentication",
+			Description:    fmt.Sprintf("%s identity from %s linked by verified email in a verified domain", strings.ToUpper(ext.Provider), ext.Issuer),
+		})
+	}
+	return &user, outcome, nil
+}
+
+// createUser provisions a user for a first-time SSO sign-in. SSO users have
+// no password; their email counts as verified only when the identity
+// provider is trusted for it.
+func (s *IdentityService) createUser(ext *ExternalIdentity, verified bool) (*models.User, error) {
+	base := ext.Email
+	if at := strings.Index(base, "@"); at > 0 {
+		base = base[:at]
+	}
+	base = strings.Trim(usernameUnsafe.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if len(base) < 3 {
+		base = "user-" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		username := base
+		if attempt > 0 {
+			username = fmt.Sprintf("%s-%d", base, attempt+1)
+		}
+		var taken int64
+		s.db.Model(&models.User{}).Where("LOWER(username) = ?", username).Count(&taken)
+		if taken > 0 {
+			continue
+		}
+		user := &models.User{
+			Username:     username,
+			Email:        ext.Email,
+			PasswordHash: "",
+			FullName:     ext.FullName,
+			IsActive:     true,
+			IsVerified:   verified,
+		}
+		if err := s.db.Create(user).Error; err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		return user, nil
+	}
+	return nil, fmt.Errorf("failed to find a free username for %s", ext.Email)
+}
+
+// ListIdentities returns the identities linked to a user
+func (s *IdentityService) ListIdentities(userID uint) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	err := s.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error
+	return identities, err
+}
+
+// UnlinkIdentity removes one of a user's identities, unless it is the only
+// way left to sign in
+func (s *IdentityService) UnlinkIdentity(userID, identityID uint) error {
+	var identity UserIdentity
+	if err := s.db.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+		return err
+	}
+	var user models.User
+	if err := s.db.Select("id, password_hash").First(&user, userID).Error; err != nil {
+		return err
+	}
+	var count int64
+	s.db.Model(&UserIdentity{}).Where("user_id = ?", userID).Count(&count)
+	if user.PasswordHash == "" && count <= 1 {
+		return ErrLastIdentity
+	}
+	return s.db.Delete(&identity).Error
+}
+
+// hasActiveSubscription reports whether a user pays for a plan
+func hasActiveSubscription(user *models.User) bool {
+	return user.SubscriptionID != "" && (user.SubscriptionStatus == "active" || user.SubscriptionStatus == "trialing" || user.SubscriptionStatus == "past_due")
+}
+
+// PlanMerge describes merging source into target without changing anything
+func (s *IdentityService) PlanMerge(sourceID, targetID uint) (*MergePlan, error) {
+	return s.planMerge(s.db, sourceID, targetID)
+}
+
+func (s *IdentityService) planMerge(db *gorm.DB, sourceID, targetID uint) (*MergePlan, error) {
+	if sourceID == 0 || targetID == 0 || sourceID == targetID {
+		return nil, fmt.Errorf("%w: source and target must be two different accounts", ErrInvalidMerge)
+	}
+	var source, target models.User
+	if err := db.First(&source, sourceID).Error; err != nil {
+		return nil, fmt.Errorf("%w: source account not found", ErrInvalidMerge)
+	}
+	if err := db.First(&target, targetID).Error; err != nil {
+		return nil, fmt.Errorf("%w: target account not found", ErrInvalidMerge)
+	}
+
+	plan := &MergePlan{
+		SourceUserID:     source.ID,
+		SourceEmail:      source.Email,
+		TargetUserID:     target.ID,
+		TargetEmail:      target.Email,
+		CreditBalanceUSD: source.CreditBalance,
+	}
+	db.Model(&models.Project{}).Where("owner_id = ?", source.ID).Count(&plan.Projects)
+	db.Model(&UserIdentity{}).Where("user_id = ?", source.ID).Count(&plan.Identities)
+	db.Model(&OrganizationMember{}).Where("user_id = ?", source.ID).Count(&plan.Memberships)
+
+	if normalizeEmail(source.Email) != normalizeEmail(target.Email) {
+		plan.Blockers = append(plan.Blockers, "accounts have different email addresses")
+	}
+	if !target.IsActive {
+		plan.Blockers = append(plan.Blockers, "target account is disabled")
+	}
+	if hasActiveSubscription(&source) {
+		if hasActiveSubscription(&target) {
+			plan.Blockers = append(plan.Blockers, "both accounts have an active subscription; cancel one first")
+		} else {
+			plan.MoveSubscription = true
+		}
+	} else if source.StripeCustomerID != "" && target.StripeCustomerID == "" {
+		plan.MoveSubscription = true
+	}
+	if source.CreditBalance < 0 {
+		plan.Blockers = append(plan.Blockers, "source account has a negative credit balance")
+	}
+	return plan, nil
+}
+
+// Merge moves the source account's projects, identities, organization
+// memberships, credits and subscription to the target and disables the
+// source. The source keeps its usage history and can no longer sign in.
+func (s *IdentityService) Merge(sourceID, targetID uint, orgID, actorID *uint) (*MergePlan, error) {
+	var plan *MergePlan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = s.planMerge(tx, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		if len(plan.Blockers) > 0 {
+			return fmt.Errorf("%w: %s", ErrMergeBlocked, strings.Join(plan.Blockers, "; "))
+		}
+		var source models.User
+		if err := tx.First(&source, sourceID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Project{}).Where("owner_id = ?", sourceID).Update("owner_id", targetID).Error; err != nil {
+			return fmt.Errorf("move projects: %w", err)
+		}
+		if err := tx.Model(&UserIdentity{}).Where("user_id = ?", sourceID).
+			Updates(map[string]interface{}{"user_id": targetID, "linked_by": IdentityLinkedMerge}).Error; err != nil {
+			return fmt.Errorf("move identities: %w", err)
+		}
+
+		// Memberships move unless the target already belongs to the
+		// organization, in which case the target's role wins
+		var memberships []OrganizationMember
+		if err := tx.Where("user_id = ?", sourceID).Find(&memberships).Error; err != nil {
+			return err
+		}
+		for _, membership := range memberships {
+			var existing int64
+			tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", membership.OrganizationID, targetID).Count(&existing)
+			if existing > 0 {
+				if err := tx.Delete(&OrganizationMember{}, membership.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&OrganizationMember{}).Where("id = ?", membership.ID).Update("user_id", targetID).Error; err != nil {
+				return err
+			}
+		}
+
+		if plan.CreditBalanceUSD > 0 {
+			if err := payments.ApplyCreditGrant(tx, sourceID, -plan.CreditBalanceUSD, CreditEntryTypeAccountMerge,
+				fmt.Sprintf("Credits moved to merged account #%d", targetID), "", "", ""); err != nil {
+				return err
+			}
+			if err := payments.ApplyCreditGrant(tx, targetID, plan.CreditBalanceUSD, CreditEntryTypeAccountMerge,
+				fmt.Sprintf("Credits moved from merged account #%d", sourceID), "", "", ""); err != nil {
+				return err
+			}
+		}
+		if plan.MoveSubscription {
+			if err := tx.Model(&models.User{}).Where("id = ?", targetID).Updates(map[string]interface{}{
+				"stripe_customer_id":  source.StripeCustomerID,
+				"subscription_id":     source.SubscriptionID,
+				"subscription_status": source.SubscriptionStatus,
+				"subscription_type":   source.SubscriptionType,
+				"billing_cycle_start": source.BillingCycleStart,
+			}).Error; err != nil {
+				return fmt.Errorf("move subscription: %w", err)
+			}
+		}
+
+		// The source is disabled and its email freed so the address only
+		// ever resolves to the target
+		sourceUpdates := map[string]interface{}{
+			"is_active": false,
+			"email":     fmt.Sprintf("merged-%d+%s", sourceID, source.Email),
+		}
+		if plan.MoveSubscription {
+			sourceUpdates["stripe_customer_id"] = ""
+			sourceUpdates["subscription_id"] = ""
+			sourceUpdates["subscription_status"] = "canceled"
+			sourceUpdates["subscription_type"] = string(payments.PlanFree)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", sourceID).Updates(sourceUpdates).Error; err != nil {
+			return fmt.Errorf("disable source account: %w", err)
+		}
+		now := time.Now()
+		if err := tx.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked = ?", sourceID, false).
+			Updates(map[string]interface{}{"revoked": true, "revoked_at": now}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&AccountMerge{
+			SourceUserID:   sourceID,
+			TargetUserID:   targetID,
+			OrganizationID: orgID,
+			ActorID:        actorID,
+			Plan:           *plan,
+		}).Error
+	})
+	if err != nil {
+		return plan, err
+	}
+
+	if s.audit != nil {
+		s.audit.LogEvent(&AuditLog{
+			OrganizationID: orgID,
+			UserID:         actorID,
+			Action:         "account_merged",
+			ResourceType:   "user",
+			ResourceID:     strconv.FormatUint(uint64(targetID), 10),
+			ResourceName:   plan.TargetEmail,
+			Category:       "authentication",
+			Severity:       "warning",
+			Description:    fmt.Sprintf("Account #%d merged into #%d", sourceID, targetID),
+			Metadata: map[string]interface{}{
+				"source_user_id":    sourceID,
+				"projects":          plan.Projects,
+				"identities":        plan.Identities,
+				"credits_usd":       plan.CreditBalanceUSD,
+				"move_subscription": plan.MoveSubscription,
+			},
+		})
+	}
+	return plan, nil
+}
+
+// mergeCandidate is a user sharing an email with an organization member
+type mergeCandidate struct {
+	ID           uint
+	Email        string
+	PasswordHash string
+	IsVerified   bool
+	IsActive     bool
+	CreatedAt    time.Time
+	Identities   int64
+}
+
+// MigrateOrganization finds organization members whose email is shared by
+// more than one account (for example a password account and a duplicate
+// created by SSO) and merges each group into its password account. Members
+// with a single verified account are reported as linking on their next SSO
+// sign-in. Only emails in the organization's verified domains are
+// considered; the organization cannot vouch for any other address. With
+// dryRun nothing is changed.
+func (s *IdentityService) MigrateOrganization(orgID uint, actorID *uint, dryRun bool) (*MigrationReport, error) {
+	report := &MigrationReport{OrganizationID: orgID, DryRun: dryRun, Items: []MigrationItem{}}
+
+	var emails []string
+	err := s.db.Table("organization_members").
+		Joins("JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ? AND organization_members.deleted_at IS NULL AND users.is_active = ?", orgID, true).
+		Pluck("LOWER(users.email)", &emails).Error
+	if err != nil {
+		return nil, err
+	}
+	report.Members = len(emails)
+	seen := make(map[string]bool, len(emails))
+	sort.Strings(emails)
+
+	for _, email := range emails {
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		if !s.IsVerifiedDomain(orgID, email) {
+			report.Skipped++
+			report.Items = append(report.Items, MigrationItem{
+				Email:  email,
+				Action: MigrationActionSkip,
+				Reason: "email domain is not verified for this organization",
+			})
+			continue
+		}
+
+		var candidates []mergeCandidate
+		err := s.db.Model(&models.User{}).
+			Select("id, email, password_hash, is_verified, is_active, created_at").
+			Where("LOWER(email) = ? AND is_active = ?", email, true).
+			Order("created_at ASC").
+			Scan(&candidates).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range candidates {
+			s.db.Model(&UserIdentity{}).Where("user_id = ?", candidates[i].ID).Count(&candidates[i].Identities)
+		}
+
+		item := s.planMigrationItem(email, candidates)
+		if item.Action == MigrationActionMerge && !dryRun {
+			item.Applied = true
+			for _, plan := range item.Merges {
+				if _, err := s.Merge(plan.SourceUserID, plan.TargetUserID, &orgID, actorID); err != nil {
+					item.Applied = false
+					item.Error = err.Error()
+					break
+				}
+			}
+		}
+
+		switch {
+		case item.Error != "":
+			report.Failed++
+		case item.Action == MigrationActionMerge:
+			report.Merges += len(item.Merges)
+		case item.Action == MigrationActionLinkOnLogin:
+			report.LinkOnLogin++
+		case item.Action == MigrationActionSkip:
+			report.Skipped++
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	if !dryRun && s.audit != nil {
+		s.audit.LogEvent(&AuditLog{
+			OrganizationID: &orgID,
+			UserID:         actorID,
+			Action:         "identity_migration_run",
+			ResourceType:   "organization",
+			ResourceID:     strconv.FormatUint(uint64(orgID), 10),
+			Category:       "authentication",
+			Description:    fmt.Sprintf("Identity migration merged %d accounts (%d failed)", report.Merges, report.Failed),
+		})
+	}
+	return report, nil
+}
+
+// planMigrationItem decides what happens to the accounts sharing an email.
+// The password account is kept because it is the one the person has been
+// signing in to; SSO-only duplicates are merged into it.
+func (s *IdentityService) planMigrationItem(email string, candidates []mergeCandidate) MigrationItem {
+	item := MigrationItem{Email: email}
+	if len(candidates) == 0 {
+		item.Action = MigrationActionSkip
+		item.Reason = "no active account"
+		return item
+	}
+	if len(candidates) == 1 {
+		candidate := candidates[0]
+		item.TargetUserID = candidate.ID
+		switch {
+		case candidate.Identities > 0:
+			item.Action = MigrationActionSkip
+			item.Reason = "already linked"
+		case !candidate.IsVerified:
+			item.Action = MigrationActionSkip
+			item.Reason = "email not verified; the user must verify it before SSO can link"
+		default:
+			item.Action = MigrationActionLinkOnLogin
+		}
+		return item
+	}
+
+	var withPassword []mergeCandidate
+	for _, candidate := range candidates {
+		if candidate.PasswordHash != "" {
+			withPassword = append(withPassword, candidate)
+		}
+	}
+	target := candidates[0]
+	switch len(withPassword) {
+	case 0:
+	case 1:
+		target = withPassword[0]
+	default:
+		item.Action = MigrationActionSkip
+		item.Reason = "more than one account has a password; merge them manually"
+		return item
+	}
+	if !target.IsVerified {
+		item.Action = MigrationActionSkip
+		item.Reason = "the account to keep has an unverified email"
+		return item
+	}
+
+	item.Action = MigrationActionMerge
+	item.TargetUserID = target.ID
+	for _, candidate := range candidates {
+		if candidate.ID == target.ID {
+			continue
+		}
+		plan, err := s.PlanMerge(candidate.ID, target.ID)
+		if err != nil {
+			item.Action = MigrationActionSkip
+			item.Reason = err.Error()
+			item.Merges = nil
+			return item
+		}
+		if len(plan.Blockers) > 0 {
+			item.Action = MigrationActionSkip
+			item.Reason = strings.Join(plan.Blockers, "; ")
+		}
+		item.Merges = append(item.Merges, *plan)
+	}
+	return item
+}
+
+// SAMLIdentity builds the external identity for a SAML assertion. The email
+// comes from the usual email attributes, falling back to an email-shaped
+// NameID.
+func SAMLIdentity(org *Organization, assertion *SAMLAssertion) *ExternalIdentity {
+	ext := &ExternalIdentity{
+		Provider:       IdentityProviderSAML,
+		Issuer:         assertion.Issuer,
+		Subject:        assertion.Subject.NameID,
+		OrganizationID: &org.ID,
+	}
+	if ext.Issuer == "" {
+		ext.Issuer = org.SAMLEntityID
+	}
+	attrs := assertion.AttributeStatement.Attributes
+	first := func(names ...string) string {
+		for _, name := range names {
+			if values := attrs[name]; len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+				return strings.TrimSpace(values[0])
+			}
+		}
+		return ""
+	}
+	ext.Email = first("email", "mail", "Email", "emailAddress",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress")
+	if ext.Email == "" && strings.Contains(assertion.Subject.NameID, "@") {
+		ext.Email = assertion.Subject.NameID
+	}
+	ext.FullName = first("displayName", "name", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name")
+	ext.EmailVerified = strings.EqualFold(first("email_verified", "emailVerified"), "true")
+	return ext
+}
+
+// EnsureMembership adds an SSO user to the organization with its default
+// role the first time they sign in through it
+func (s *IdentityService) EnsureMembership(org *Organization, userID uint, ext *ExternalIdentity, attributes map[string][]string) error {
+	var existing int64
+	s.db.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.ID, userID).Count(&existing)
+	if existing > 0 {
+		return nil
+	}
+	var role Role
+	if err := s.db.Where("organization_id = ? AND is_default = ?", org.ID, true).First(&role).Error; err != nil {
+		if err := s.db.Where("organization_id = ?", org.ID).First(&role).Error; err != nil {
+			return fmt.Errorf("no default role for organization %d", org.ID)
+		}
+	}
+	samlAttributes := make(map[string]interface{}, len(attributes))
+	for name, values := range attributes {
+		samlAttributes[name] = values
+	}
+	now := time.Now()
+	return s.db.Create(&OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         userID,
+		RoleID:         role.ID,
+		SAMLNameID:     ext.Subject,
+		SAMLAttributes: samlAttributes,
+		ProvisionedBy:  "saml",
+		Status:         "active",
+		JoinedAt:       &now,
+	}).Error
+}
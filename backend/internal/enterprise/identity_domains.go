@@ -0,0 +1,303 @@
+// APEX.BUILD Verified Organization Domains
+// Domains an organization proved it owns, and SSO links awaiting a signed-in user
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// IdentityLinkedSession marks an identity linked by its signed-in user
+const IdentityLinkedSession = "session"
+
+// domainVerifyPrefix is the DNS name the TXT record is published under
+const domainVerifyPrefix = "_apex-verify."
+
+// pendingLinkTTL is how long a user has to sign in and accept a link
+const pendingLinkTTL = 15 * time.Minute
+
+var (
+	// ErrLinkRequired is returned when an SSO email matches an account the
+	// identity provider cannot vouch for. The account's owner must sign in
+	// and link the identity; see LinkRequiredError.
+	ErrLinkRequired = errors.New("an account with this email exists; sign in to it to link this identity")
+	// ErrInvalidDomain wraps a malformed or already claimed domain
+	ErrInvalidDomain = errors.New("invalid domain")
+	// ErrDomainNotVerified is returned when the TXT record is missing
+	ErrDomainNotVerified = errors.New("domain verification record not found")
+	// ErrLinkExpired is returned for an unknown or expired link token
+	ErrLinkExpired = errors.New("identity link request is invalid or has expired")
+)
+
+// LinkRequiredError carries the token the signed-in user presents to link
+// the identity. It matches ErrLinkRequired.
+type LinkRequiredError struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (e *LinkRequiredError) Error() string { return ErrLinkRequired.Error() }
+
+// Is makes errors.Is(err, ErrLinkRequired) match
+func (e *LinkRequiredError) Is(target error) bool { return target == ErrLinkRequired }
+
+// OrganizationDomain is an email domain an organization has claimed. Once
+// verified, its identity provider is trusted to vouch for addresses in it.
+type OrganizationDomain struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID    uint       `json:"organization_id" gorm:"not null;index"`
+	Domain            string     `json:"domain" gorm:"size:253;not null;uniqueIndex"`
+	VerificationToken string     `json:"verification_token" gorm:"size:64;not null"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+}
+
+// TableName specifies the table name for OrganizationDomain
+func (OrganizationDomain) TableName() string {
+	return "organization_domains"
+}
+
+// RecordName is the DNS name the verification TXT record goes under
+func (d *OrganizationDomain) RecordName() string {
+	return domainVerifyPrefix + d.Domain
+}
+
+// PendingIdentityLink is an SSO identity waiting for the owner of the
+// matching account to sign in and accept it
+type PendingIdentityLink struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TokenHash      string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Provider       string    `json:"provider" gorm:"size:16;not null"`
+	Issuer         string    `json:"issuer" gorm:"size:255;not null"`
+	Subject        string    `json:"subject" gorm:"size:255;not null"`
+	Email          string    `json:"email"`
+	OrganizationID *uint     `json:"organization_id,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at" gorm:"index"`
+}
+
+// TableName specifies the table name for PendingIdentityLink
+func (PendingIdentityLink) TableName() string {
+	return "pending_identity_links"
+}
+
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// emailDomain returns the lowercased domain of an email address
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return normalizeEmail(email[at+1:])
+}
+
+// normalizeDomain lowercases a domain and checks it is a plausible hostname
+func normalizeDomain(domain string) (string, bool) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) < 3 || len(domain) > 253 || !strings.Contains(domain, ".") {
+		return "", false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for _, r := range label {
+			if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-') {
+				return "", false
+			}
+		}
+	}
+	return domain, true
+}
+
+// IsVerifiedDomain reports whether the email's domain is verified for the
+// organization
+func (s *IdentityService) IsVerifiedDomain(orgID uint, email string) bool {
+	domain := emailDomain(email)
+	if domain == "" {
+		return false
+	}
+	var count int64
+	s.db.Model(&OrganizationDomain{}).
+		Where("organization_id = ? AND domain = ? AND verified_at IS NOT NULL", orgID, domain).
+		Count(&count)
+	return count > 0
+}
+
+// ListDomains returns an organization's claimed domains
+func (s *IdentityService) ListDomains(orgID uint) ([]OrganizationDomain, error) {
+	var domains []OrganizationDomain
+	err := s.db.Where("organization_id = ?", orgID).Order("domain ASC").Find(&domains).Error
+	return domains, err
+}
+
+// AddDomain claims a domain for an organization. It stays unverified until
+// VerifyDomain finds its token in DNS.
+func (s *IdentityService) AddDomain(orgID uint, domain string) (*OrganizationDomain, error) {
+	normalized, ok := normalizeDomain(domain)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a domain name", ErrInvalidDomain, domain)
+	}
+	var taken int64
+	s.db.Model(&OrganizationDomain{}).Where("domain = ?", normalized).Count(&taken)
+	if taken > 0 {
+		return nil, fmt.Errorf("%w: %s is already claimed", ErrInvalidDomain, normalized)
+	}
+	record := &OrganizationDomain{
+		OrganizationID:    orgID,
+		Domain:            normalized,
+		VerificationToken: "apex-verify-" + generateRandomID(32),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// VerifyDomain looks up the domain's TXT records and marks it verified when
+// one holds its token
+func (s *IdentityService) VerifyDomain(orgID, domainID uint) (*OrganizationDomain, error) {
+	var record OrganizationDomain
+	if err := s.db.Where("id = ? AND organization_id = ?", domainID, orgID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	if record.VerifiedAt != nil {
+		return &record, nil
+	}
+	txtRecords, err := s.lookupTXT(record.RecordName())
+	if err != nil {
+		return &record, fmt.Errorf("%w: DNS lookup of %s failed: %v", ErrDomainNotVerified, record.RecordName(), err)
+	}
+	for _, txt := range txtRecords {
+		if strings.TrimSpace(txt) == record.VerificationToken {
+			now := time.Now()
+			if err := s.db.Model(&record).Update("verified_at", now).Error; err != nil {
+				return nil, err
+			}
+			record.VerifiedAt = &now
+			if s.audit != nil {
+				s.audit.LogEvent(&AuditLog{
+					OrganizationID: &orgID,
+					Action:         "domain_verified",
+					ResourceType:   "organization_domain",
+					ResourceName:   record.Domain,
+					Category:       "authentication",
+					Description:    fmt.Sprintf("Verified ownership of %s", record.Domain),
+				})
+			}
+			return &record, nil
+		}
+	}
+	return &record, fmt.Errorf("%w: no TXT record at %s holds the token", ErrDomainNotVerified, record.RecordName())
+}
+
+// DeleteDomain releases a claimed domain. Identities already linked stay
+// linked; new sign-ins from it need explicit linking again.
+func (s *IdentityService) DeleteDomain(orgID, domainID uint) error {
+	result := s.db.Where("id = ? AND organization_id = ?", domainID, orgID).Delete(&OrganizationDomain{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// requestLink stores an identity for the matching account's owner to accept
+// and returns the LinkRequiredError carrying its token
+func (s *IdentityService) requestLink(ext *ExternalIdentity) error {
+	now := time.Now()
+	s.db.Where("expires_at < ?", now).Delete(&PendingIdentityLink{})
+	token := generateRandomID(48)
+	pending := &PendingIdentityLink{
+		TokenHash:      hashLinkToken(token),
+		Provider:       ext.Provider,
+		Issuer:         ext.Issuer,
+		Subject:        ext.Subject,
+		Email:          ext.Email,
+		OrganizationID: ext.OrganizationID,
+		ExpiresAt:      now.Add(pendingLinkTTL),
+	}
+	if err := s.db.Create(pending).Error; err != nil {
+		return err
+	}
+	return &LinkRequiredError{Token: token, ExpiresAt: pending.ExpiresAt}
+}
+
+// LinkPending links a pending identity to the signed-in user who presents
+// its token. The user's email must still match the one the identity
+// provider sent.
+func (s *IdentityService) LinkPending(userID uint, token string) (*UserIdentity, error) {
+	var pending PendingIdentityLink
+	if err := s.db.Where("token_hash = ? AND expires_at > ?", hashLinkToken(token), time.Now()).First(&pending).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLinkExpired
+		}
+		return nil, err
+	}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if normalizeEmail(user.Email) != pending.Email {
+		return nil, fmt.Errorf("%w: the identity belongs to a different email address", ErrInvalidIdentity)
+	}
+
+	now := time.Now()
+	identity := &UserIdentity{
+		UserID:         userID,
+		Provider:       pending.Provider,
+		Issuer:         pending.Issuer,
+		Subject:        pending.Subject,
+		OrganizationID: pending.OrganizationID,
+		Email:          pending.Email,
+		LinkedBy:       IdentityLinkedSession,
+		LastUsedAt:     &now,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&pending).Error; err != nil {
+			return err
+		}
+		var existing int64
+		tx.Model(&UserIdentity{}).Where("provider = ? AND issuer = ? AND subject = ?", pending.Provider, pending.Issuer, pending.Subject).Count(&existing)
+		if existing > 0 {
+			return fmt.Errorf("%w: the identity is already linked", ErrInvalidIdentity)
+		}
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.audit != nil {
+		s.audit.LogEvent(&AuditLog{
+			OrganizationID: pending.OrganizationID,
+			UserID:         &userID,
+			Username:       user.Username,
+			Email:          user.Email,
+			Action:         "identity_linked",
+			ResourceType:   "user",
+			ResourceID:     fmt.Sprint(userID),
+			Category:       "authentication",
+			Description:    fmt.Sprintf("%s identity from %s linked by its signed-in user", strings.ToUpper(pending.Provider), pending.Issuer),
+		})
+	}
+	return identity, nil
+}
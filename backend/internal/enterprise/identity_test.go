@@ -0,0 +1,274 @@
+package enterprise
+
+import (
+	"errors"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupIdentityTest(t *testing.T) (*IdentityService, *gorm.DB, *Organization) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.RefreshToken{}, &models.CreditLedgerEntry{}, &models.ProcessedStripeEvent{},
+		&Organization{}, &Role{}, &OrganizationMember{}, &AuditLog{}, &UserIdentity{}, &AccountMerge{},
+		&OrganizationDomain{}, &PendingIdentityLink{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	org := &Organization{Name: "Acme", Slug: "acme"}
+	if err := db.Create(org).Error; err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+	orgID := org.ID
+	if err := db.Create(&Role{OrganizationID: &orgID, Name: "Developer", IsDefault: true}).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	service := NewIdentityService(db, NewAuditService(db))
+	service.lookupTXT = func(string) ([]string, error) { return nil, errors.New("no such host") }
+	return service, db, org
+}
+
+// verifyTestDomain claims and verifies a domain for the organization
+func verifyTestDomain(t *testing.T, service *IdentityService, orgID uint, domain string) {
+	t.Helper()
+	record, err := service.AddDomain(orgID, domain)
+	if err != nil {
+		t.Fatalf("add domain: %v", err)
+	}
+	service.lookupTXT = func(name string) ([]string, error) {
+		if name != "_apex-verify."+domain {
+			return nil, errors.New("no such host")
+		}
+		return []string{"v=spf1 -all", record.VerificationToken}, nil
+	}
+	if _, err := service.VerifyDomain(orgID, record.ID); err != nil {
+		t.Fatalf("verify domain: %v", err)
+	}
+}
+
+func createIdentityTestUser(t *testing.T, db *gorm.DB, username, email, password string, verified bool) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: email, PasswordHash: password, IsActive: true, IsVerified: verified}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestResolveLinksVerifiedEmail(t *testing.T) {
+	service, db, org := setupIdentityTest(t)
+	verifyTestDomain(t, service, org.ID, "example.com")
+	existing := createIdentityTestUser(t, db, "ada", "Ada@Example.com", "hash", true)
+	createIdentityTestUser(t, db, "bob", "bob@example.com", "hash", false)
+
+	saml := func(subject, email string) *ExternalIdentity {
+		return &ExternalIdentity{Provider: IdentityProviderSAML, Issuer: "https://idp.example.com", Subject: subject, Email: email, EmailVerified: true, OrganizationID: &org.ID}
+	}
+
+	user, outcome, err := service.Resolve(saml("ada-nameid", "ada@example.com"))
+	if err != nil || outcome != ResolveLinked || user.ID != existing.ID {
+		t.Fatalf("first resolve = %v %q %v, want the existing account linked", user, outcome, err)
+	}
+	user, outcome, err = service.Resolve(saml("ada-nameid", ""))
+	if err != nil || outcome != ResolveExisting || user.ID != existing.ID {
+		t.Fatalf("second resolve = %v %q %v", user, outcome, err)
+	}
+
+	if _, _, err := service.Resolve(saml("bob-nameid", "bob@example.com")); !errors.Is(err, ErrUnverifiedEmail) {
+		t.Fatalf("unverified match err = %v", err)
+	}
+
+	user, outcome, err = service.Resolve(saml("carol-nameid", "carol@example.com"))
+	if err != nil || outcome != ResolveCreated || user.PasswordHash != "" || !user.IsVerified || user.Username != "carol" {
+		t.Fatalf("new user resolve = %+v %q %v", user, outcome, err)
+	}
+	if err := service.EnsureMembership(org, user.ID, saml("carol-nameid", ""), nil); err != nil {
+		t.Fatalf("ensure membership: %v", err)
+	}
+	if err := service.EnsureMembership(org, user.ID, saml("carol-nameid", ""), nil); err != nil {
+		t.Fatalf("ensure membership again: %v", err)
+	}
+	var members int64
+	db.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.ID, user.ID).Count(&members)
+	if members != 1 {
+		t.Fatalf("members = %d, want 1", members)
+	}
+
+	identities, _ := service.ListIdentities(user.ID)
+	if len(identities) != 1 {
+		t.Fatalf("identities = %d", len(identities))
+	}
+	if err := service.UnlinkIdentity(user.ID, identities[0].ID); !errors.Is(err, ErrLastIdentity) {
+		t.Fatalf("unlink last identity err = %v", err)
+	}
+	identities, _ = service.ListIdentities(existing.ID)
+	if err := service.UnlinkIdentity(existing.ID, identities[0].ID); err != nil {
+		t.Fatalf("unlink with password: %v", err)
+	}
+}
+
+func TestMigrateOrganizationMergesDuplicates(t *testing.T) {
+	service, db, org := setupIdentityTest(t)
+	verifyTestDomain(t, service, org.ID, "example.com")
+	password := createIdentityTestUser(t, db, "ada", "ada@example.com", "hash", true)
+	duplicate := createIdentityTestUser(t, db, "ada-sso", "ADA@example.com", "", true)
+	single := createIdentityTestUser(t, db, "grace", "grace@example.com", "hash", true)
+	for _, user := range []*models.User{password, duplicate, single} {
+		if err := db.Create(&OrganizationMember{OrganizationID: org.ID, UserID: user.ID, RoleID: 1, Status: "active"}).Error; err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+	}
+	if err := db.Create(&models.Project{Name: "sso-project", Language: "go", OwnerID: duplicate.ID}).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	db.Model(&models.User{}).Where("id = ?", duplicate.ID).Updates(map[string]interface{}{
+		"credit_balance": 7.5, "stripe_customer_id": "cus_1", "subscription_id": "sub_1", "subscription_status": "active", "subscription_type": "pro",
+	})
+	if err := db.Create(&UserIdentity{UserID: duplicate.ID, Provider: IdentityProviderSAML, Issuer: "idp", Subject: "ada"}).Error; err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+
+	report, err := service.MigrateOrganization(org.ID, nil, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Merges != 1 || report.LinkOnLogin != 1 || report.Items[0].Applied {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	plan := report.Items[0].Merges[0]
+	if plan.SourceUserID != duplicate.ID || plan.TargetUserID != password.ID || plan.Projects != 1 || !plan.MoveSubscription {
+		t.Fatalf("plan = %+v", plan)
+	}
+	var owner uint
+	db.Model(&models.Project{}).Where("name = ?", "sso-project").Select("owner_id").Scan(&owner)
+	if owner != duplicate.ID {
+		t.Fatal("dry run moved the project")
+	}
+
+	report, err = service.MigrateOrganization(org.ID, nil, false)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if !report.Items[0].Applied || report.Failed != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	db.Model(&models.Project{}).Where("name = ?", "sso-project").Select("owner_id").Scan(&owner)
+	if owner != password.ID {
+		t.Fatalf("project owner = %d, want %d", owner, password.ID)
+	}
+	var target, source models.User
+	db.First(&target, password.ID)
+	db.First(&source, duplicate.ID)
+	if target.CreditBalance != 7.5 || target.SubscriptionID != "sub_1" || target.SubscriptionType != "pro" {
+		t.Fatalf("target = %+v", target)
+	}
+	if source.IsActive || source.CreditBalance != 0 || source.SubscriptionID != "" {
+		t.Fatalf("source = %+v", source)
+	}
+	var identity UserIdentity
+	db.First(&identity)
+	if identity.UserID != password.ID || identity.LinkedBy != IdentityLinkedMerge {
+		t.Fatalf("identity = %+v", identity)
+	}
+	var members int64
+	db.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.ID, password.ID).Count(&members)
+	if members != 1 {
+		t.Fatalf("target memberships = %d, want 1", members)
+	}
+}
+
+func TestResolveRequiresLinkingOutsideVerifiedDomains(t *testing.T) {
+	service, db, org := setupIdentityTest(t)
+	victim := createIdentityTestUser(t, db, "victim", "victim@gmail.com", "hash", true)
+	staff := createIdentityTestUser(t, db, "staff", "staff@example.com", "hash", true)
+
+	// The organization claimed gmail.com but never proved it owns it
+	unverified, err := service.AddDomain(org.ID, "gmail.com")
+	if err != nil {
+		t.Fatalf("add domain: %v", err)
+	}
+	if _, err := service.VerifyDomain(org.ID, unverified.ID); !errors.Is(err, ErrDomainNotVerified) {
+		t.Fatalf("verify without TXT record err = %v", err)
+	}
+	verifyTestDomain(t, service, org.ID, "example.com")
+	if _, err := service.AddDomain(org.ID+1, "Example.COM."); !errors.Is(err, ErrInvalidDomain) {
+		t.Fatalf("claiming a taken domain err = %v", err)
+	}
+
+	ext := &ExternalIdentity{Provider: IdentityProviderSAML, Issuer: "https://evil-idp", Subject: "victim", Email: "victim@gmail.com", EmailVerified: true, OrganizationID: &org.ID}
+	_, _, err = service.Resolve(ext)
+	var linkErr *LinkRequiredError
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrLinkRequired) || linkErr.Token == "" {
+		t.Fatalf("unverified domain resolve err = %v, want a link request", err)
+	}
+	// Nor does a verified domain help when the IdP does not vouch for the email
+	if _, _, err := service.Resolve(&ExternalIdentity{Provider: IdentityProviderSAML, Issuer: "https://idp", Subject: "staff", Email: "staff@example.com", OrganizationID: &org.ID}); !errors.Is(err, ErrLinkRequired) {
+		t.Fatalf("unverified email claim err = %v, want ErrLinkRequired", err)
+	}
+	var linked int64
+	db.Model(&UserIdentity{}).Count(&linked)
+	if linked != 0 {
+		t.Fatalf("identities = %d, want none linked", linked)
+	}
+
+	// Someone else signed in cannot accept the link
+	if _, err := service.LinkPending(staff.ID, linkErr.Token); !errors.Is(err, ErrInvalidIdentity) {
+		t.Fatalf("link by another account err = %v", err)
+	}
+	identity, err := service.LinkPending(victim.ID, linkErr.Token)
+	if err != nil || identity.UserID != victim.ID || identity.LinkedBy != IdentityLinkedSession {
+		t.Fatalf("link by owner = %+v, %v", identity, err)
+	}
+	if _, err := service.LinkPending(victim.ID, linkErr.Token); !errors.Is(err, ErrLinkExpired) {
+		t.Fatalf("reused token err = %v", err)
+	}
+	user, outcome, err := service.Resolve(ext)
+	if err != nil || outcome != ResolveExisting || user.ID != victim.ID {
+		t.Fatalf("resolve after linking = %v %q %v", user, outcome, err)
+	}
+
+	// A new user from an untrusted domain is created unverified
+	created, outcome, err := service.Resolve(&ExternalIdentity{Provider: IdentityProviderSAML, Issuer: "https://idp", Subject: "dan", Email: "dan@gmail.com", EmailVerified: true, OrganizationID: &org.ID})
+	if err != nil || outcome != ResolveCreated || created.IsVerified {
+		t.Fatalf("new user from unverified domain = %+v %q %v", created, outcome, err)
+	}
+
+	// Migration never merges addresses outside the verified domains
+	createIdentityTestUser(t, db, "victim-2", "VICTIM@gmail.com", "", true)
+	if err := db.Create(&OrganizationMember{OrganizationID: org.ID, UserID: victim.ID, RoleID: 1, Status: "active"}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	report, err := service.MigrateOrganization(org.ID, nil, false)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if report.Merges != 0 || report.Skipped != 1 || report.Items[0].Action != MigrationActionSkip {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestMergeBlockedByTwoSubscriptions(t *testing.T) {
+	service, db, _ := setupIdentityTest(t)
+	a := createIdentityTestUser(t, db, "ada", "ada@example.com", "hash", true)
+	b := createIdentityTestUser(t, db, "ada2", "Ada@example.com", "", true)
+	for _, user := range []*models.User{a, b} {
+		db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{"subscription_id": "sub", "subscription_status": "active"})
+	}
+	if _, err := service.Merge(b.ID, a.ID, nil, nil); !errors.Is(err, ErrMergeBlocked) {
+		t.Fatalf("merge err = %v", err)
+	}
+	other := createIdentityTestUser(t, db, "grace", "grace@example.com", "hash", true)
+	plan, _ := service.PlanMerge(other.ID, a.ID)
+	if len(plan.Blockers) == 0 {
+		t.Fatal("merging different emails should be blocked")
+	}
+	if _, err := service.PlanMerge(a.ID, a.ID); !errors.Is(err, ErrInvalidMerge) {
+		t.Fatalf("self merge err = %v", err)
+	}
+}
@@ -57,6 +57,18 @@ type Organization struct {
 	AuditLogRetentionDays int `json:"audit_log_retention_days" gorm:"default:90"`
 	DataRetentionDays     int `json:"data_retention_days" gorm:"default:365"`
 
+	// Legal hold suspends retention purges and keeps member audit logs
+	// (pseudonymized) through account deletion.
+	LegalHold       bool       `json:"legal_hold" gorm:"default:false"`
+	LegalHoldReason string     `json:"legal_hold_reason,omitempty"`
+	LegalHoldSince  *time.Time `json:"legal_hold_since,omitempty"`
+
+	// AI instructions added to every agent, completion and chat prompt on
+	// the organization's projects. They take precedence over a project's
+	// apex.md, which members may only use while AllowProjectAIInstructions.
+	AIInstructions             string `json:"ai_instructions,omitempty" gorm:"type:text"`
+	AllowProjectAIInstructions bool   `json:"allow_project_ai_instructions" gorm:"default:true"`
+
 	// Relationships
 	Members      []OrganizationMember `json:"members" gorm:"foreignKey:OrganizationID"`
 	Roles        []Role               `json:"roles" gorm:"foreignKey:OrganizationID"`
@@ -0,0 +1,565 @@
+// APEX.BUILD Network Policies
+// Organization IP allowlists and geo restrictions for API and SSO access
+
+package enterprise
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Network policy denial reasons
+const (
+	NetworkDeniedIP             = "ip_not_allowed"
+	NetworkDeniedCountry        = "country_not_allowed"
+	NetworkDeniedBlockedCountry = "country_blocked"
+	NetworkDeniedUnknownCountry = "country_unknown"
+)
+
+const (
+	// MaxNetworkPolicyEntries caps each list of a network policy
+	MaxNetworkPolicyEntries = 200
+	// networkPolicyCacheTTL is how long a user's policies are cached
+	networkPolicyCacheTTL = 30 * time.Second
+	// networkAuditInterval limits repeated denial and break-glass audit
+	// entries for the same user, organization and address
+	networkAuditInterval = 10 * time.Minute
+)
+
+var (
+	// ErrInvalidNetworkPolicy wraps invalid policy settings
+	ErrInvalidNetworkPolicy = errors.New("invalid network policy")
+	// ErrNetworkPolicyLockout is returned when a policy would block the
+	// administrator saving it
+	ErrNetworkPolicyLockout = errors.New("network policy would block your current connection")
+)
+
+// NetworkPolicy restricts the networks an organization's members may use
+// the API and SSO from. An address passes when it is in an allowed CIDR or
+// an allowed country; blocked countries are always refused. Break-glass
+// users bypass API enforcement so administrators cannot lock everyone out.
+type NetworkPolicy struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uint `json:"organization_id" gorm:"not null;uniqueIndex"`
+	Enabled        bool `json:"enabled" gorm:"default:false"`
+	EnforceAPI     bool `json:"enforce_api"`
+	EnforceSSO     bool `json:"enforce_sso"`
+
+	AllowedCIDRs      []string `json:"allowed_cidrs" gorm:"type:text;serializer:json"`
+	AllowedCountries  []string `json:"allowed_countries" gorm:"type:text;serializer:json"` // ISO 3166-1 alpha-2
+	BlockedCountries  []string `json:"blocked_countries" gorm:"type:text;serializer:json"`
+	BreakGlassUserIDs []uint   `json:"break_glass_user_ids" gorm:"type:text;serializer:json"`
+
+	UpdatedBy *uint `json:"updated_by,omitempty"`
+}
+
+// TableName specifies the table name for NetworkPolicy
+func (NetworkPolicy) TableName() string {
+	return "organization_network_policies"
+}
+
+// NetworkDenial explains why a request was refused by a network policy
+type NetworkDenial struct {
+	OrganizationID   uint   `json:"organization_id"`
+	OrganizationName string `json:"organization_name"`
+	Reason           string `json:"reason"`
+	IPAddress        string `json:"ip_address"`
+	Country          string `json:"country,omitempty"`
+}
+
+// Message describes the denial for the person who was refused
+func (d *NetworkDenial) Message() string {
+	switch d.Reason {
+	case NetworkDeniedBlockedCountry:
+		return fmt.Sprintf("%s does not allow access from %s.", d.OrganizationName, d.Country)
+	case NetworkDeniedCountry:
+		return fmt.Sprintf("%s only allows access from approved countries; your connection appears to come from %s.", d.OrganizationName, d.Country)
+	case NetworkDeniedUnknownCountry:
+		return fmt.Sprintf("%s only allows access from approved countries, and the country of your connection could not be determined.", d.OrganizationName)
+	}
+	return fmt.Sprintf("%s only allows access from approved networks; %s is not one of them.", d.OrganizationName, d.IPAddress)
+}
+
+// RespondNetworkDenied aborts a request refused by a network policy
+func RespondNetworkDenied(c *gin.Context, denial *NetworkDenial) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error":             denial.Message(),
+		"error_code":        "network_policy_denied",
+		"reason":            denial.Reason,
+		"organization_id":   denial.OrganizationID,
+		"organization_name": denial.OrganizationName,
+		"ip_address":        denial.IPAddress,
+		"country":           denial.Country,
+	})
+}
+
+// CountryResolver finds the country a request comes from. It returns ""
+// when the country is unknown.
+type CountryResolver interface {
+	Country(r *http.Request, ip net.IP) string
+}
+
+// HeaderCountryResolver reads the country from a header set by a trusted
+// edge proxy, such as Cloudflare's CF-IPCountry
+type HeaderCountryResolver struct {
+	Header string
+}
+
+// Country implements CountryResolver
+func (h HeaderCountryResolver) Country(r *http.Request, _ net.IP) string {
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(h.Header)))
+	// XX is unknown and T1 is Tor in Cloudflare's header
+	if !isCountryCode(country) || country == "XX" || country == "T1" {
+		return ""
+	}
+	return country
+}
+
+// CountryResolverFromEnv reads the country header name from
+// GEOIP_COUNTRY_HEADER, defaulting to CF-IPCountry
+func CountryResolverFromEnv() CountryResolver {
+	header := strings.TrimSpace(os.Getenv("GEOIP_COUNTRY_HEADER"))
+	if header == "" {
+		header = "CF-IPCountry"
+	}
+	return HeaderCountryResolver{Header: header}
+}
+
+type cachedNetworkPolicies struct {
+	policies []memberNetworkPolicy
+	expires  time.Time
+}
+
+type memberNetworkPolicy struct {
+	policy  NetworkPolicy
+	orgName string
+}
+
+// NetworkPolicyService stores and enforces organization network policies
+type NetworkPolicyService struct {
+	db        *gorm.DB
+	audit     *AuditService
+	countries CountryResolver
+
+	cache   sync.Map // user ID -> *cachedNetworkPolicies
+	audited sync.Map // audit throttle key -> time.Time
+	now     func() time.Time
+}
+
+// NewNetworkPolicyService creates a network policy service
+func NewNetworkPolicyService(db *gorm.DB, audit *AuditService) *NetworkPolicyService {
+	return &NetworkPolicyService{
+		db:        db,
+		audit:     audit,
+		countries: CountryResolverFromEnv(),
+		now:       time.Now,
+	}
+}
+
+// SetCountryResolver replaces the country lookup
+func (s *NetworkPolicyService) SetCountryResolver(resolver CountryResolver) {
+	s.countries = resolver
+}
+
+// Get returns an organization's network policy, or a disabled policy when
+// none is configured
+func (s *NetworkPolicyService) Get(orgID uint) (*NetworkPolicy, error) {
+	var policy NetworkPolicy
+	err := s.db.Where("organization_id = ?", orgID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NetworkPolicy{OrganizationID: orgID, EnforceAPI: true, EnforceSSO: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Save validates and stores an organization's network policy on behalf of
+// an administrator. Enabling a policy that would refuse the administrator's
+// own request fails with ErrNetworkPolicyLockout unless they are a
+// break-glass user.
+func (s *NetworkPolicyService) Save(orgID, actorID uint, policy *NetworkPolicy, r *http.Request, clientIP string) (*NetworkPolicy, error) {
+	if err := s.normalize(orgID, policy); err != nil {
+		return nil, err
+	}
+	if policy.Enabled && !containsUint(policy.BreakGlassUserIDs, actorID) {
+		ip := net.ParseIP(clientIP)
+		if reason := policy.evaluate(ip, s.country(r, ip)); reason != "" {
+			return nil, fmt.Errorf("%w (%s from %s); add yourself as a break-glass user or allow this address first", ErrNetworkPolicyLockout, reason, clientIP)
+		}
+	}
+
+	previous, err := s.Get(orgID)
+	if err != nil {
+		return nil, err
+	}
+	policy.ID = previous.ID
+	policy.CreatedAt = previous.CreatedAt
+	policy.OrganizationID = orgID
+	policy.UpdatedBy = &actorID
+	if err := s.db.Save(policy).Error; err != nil {
+		return nil, err
+	}
+	s.cache.Range(func(key, _ interface{}) bool {
+		s.cache.Delete(key)
+		return true
+	})
+
+	if s.audit != nil {
+		s.audit.LogEvent(&AuditLog{
+			OrganizationID: &orgID,
+			UserID:         &actorID,
+			IPAddress:      clientIP,
+			UserAgent:      r.UserAgent(),
+			Action:         "network_policy_updated",
+			ResourceType:   "organization",
+			ResourceID:     strconv.FormatUint(uint64(orgID), 10),
+			Category:       "security",
+			Severity:       "warning",
+			Description:    "Organization network policy updated",
+			OldValue:       previous.auditValue(),
+			NewValue:       policy.auditValue(),
+		})
+	}
+	return policy, nil
+}
+
+// normalize validates a policy and puts its lists in canonical form
+func (s *NetworkPolicyService) normalize(orgID uint, policy *NetworkPolicy) error {
+	for name, list := range map[string]int{
+		"allowed_cidrs":        len(policy.AllowedCIDRs),
+		"allowed_countries":    len(policy.AllowedCountries),
+		"blocked_countries":    len(policy.BlockedCountries),
+		"break_glass_user_ids": len(policy.BreakGlassUserIDs),
+	} {
+		if list > MaxNetworkPolicyEntries {
+			return fmt.Errorf("%w: %s may have at most %d entries", ErrInvalidNetworkPolicy, name, MaxNetworkPolicyEntries)
+		}
+	}
+
+	cidrs := make([]string, 0, len(policy.AllowedCIDRs))
+	for _, entry := range policy.AllowedCIDRs {
+		cidr, err := normalizeCIDR(entry)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	policy.AllowedCIDRs = dedupeStrings(cidrs)
+
+	var err error
+	if policy.AllowedCountries, err = normalizeCountries("allowed_countries", policy.AllowedCountries); err != nil {
+		return err
+	}
+	if policy.BlockedCountries, err = normalizeCountries("blocked_countries", policy.BlockedCountries); err != nil {
+		return err
+	}
+	if policy.Enabled && len(policy.AllowedCIDRs) == 0 && len(policy.AllowedCountries) == 0 && len(policy.BlockedCountries) == 0 {
+		return fmt.Errorf("%w: an enabled policy needs allowed_cidrs, allowed_countries or blocked_countries", ErrInvalidNetworkPolicy)
+	}
+
+	if len(policy.BreakGlassUserIDs) > 0 {
+		var members []uint
+		if err := s.db.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id IN ? AND status = ?", orgID, policy.BreakGlassUserIDs, "active").
+			Pluck("user_id", &members).Error; err != nil {
+			return err
+		}
+		for _, userID := range policy.BreakGlassUserIDs {
+			if !containsUint(members, userID) {
+				return fmt.Errorf("%w: break-glass user %d is not an active member", ErrInvalidNetworkPolicy, userID)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i] < members[j] })
+		policy.BreakGlassUserIDs = members
+	}
+	return nil
+}
+
+// CheckAPI checks a user's API request against the policies of every
+// organization they belong to. Break-glass users are let through, and the
+// bypass is audited.
+func (s *NetworkPolicyService) CheckAPI(userID uint, r *http.Request, clientIP string) *NetworkDenial {
+	policies := s.userPolicies(userID)
+	if len(policies) == 0 {
+		return nil
+	}
+	ip := net.ParseIP(clientIP)
+	country := s.country(r, ip)
+	for _, member := range policies {
+		policy := member.policy
+		if !policy.EnforceAPI {
+			continue
+		}
+		reason := policy.evaluate(ip, country)
+		if reason == "" {
+			continue
+		}
+		denial := &NetworkDenial{
+			OrganizationID:   policy.OrganizationID,
+			OrganizationName: member.orgName,
+			Reason:           reason,
+			IPAddress:        clientIP,
+			Country:          country,
+		}
+		if containsUint(policy.BreakGlassUserIDs, userID) {
+			s.auditAccess("network_policy_break_glass", &userID, denial, r, "Break-glass user bypassed the organization network policy", "warning", "success")
+			continue
+		}
+		s.auditAccess("network_policy_denied", &userID, denial, r, "API request refused by the organization network policy", "warning", "failure")
+		return denial
+	}
+	return nil
+}
+
+// CheckSSO checks an SSO sign-in to an organization
+func (s *NetworkPolicyService) CheckSSO(org *Organization, r *http.Request, clientIP string) *NetworkDenial {
+	policy, err := s.Get(org.ID)
+	if err != nil || !policy.Enabled || !policy.EnforceSSO {
+		return nil
+	}
+	ip := net.ParseIP(clientIP)
+	country := s.country(r, ip)
+	reason := policy.evaluate(ip, country)
+	if reason == "" {
+		return nil
+	}
+	denial := &NetworkDenial{
+		OrganizationID:   org.ID,
+		OrganizationName: org.Name,
+		Reason:           reason,
+		IPAddress:        clientIP,
+		Country:          country,
+	}
+	s.auditAccess("network_policy_denied", nil, denial, r, "SSO sign-in refused by the organization network policy", "warning", "failure")
+	return denial
+}
+
+// Middleware refuses authenticated API requests from networks the user's
+// organizations do not allow
+func (s *NetworkPolicyService) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("user_id")
+		if userID == 0 {
+			c.Next()
+			return
+		}
+		if denial := s.CheckAPI(userID, c.Request, c.ClientIP()); denial != nil {
+			RespondNetworkDenied(c, denial)
+			return
+		}
+		c.Next()
+	}
+}
+
+// userPolicies returns the enabled policies of the user's organizations
+func (s *NetworkPolicyService) userPolicies(userID uint) []memberNetworkPolicy {
+	now := s.now()
+	if cached, ok := s.cache.Load(userID); ok {
+		entry := cached.(*cachedNetworkPolicies)
+		if now.Before(entry.expires) {
+			return entry.policies
+		}
+	}
+
+	var rows []struct {
+		NetworkPolicy
+		OrgName string
+	}
+	err := s.db.Table("organization_network_policies AS p").
+		Select("p.*, o.name AS org_name").
+		Joins("JOIN organization_members AS m ON m.organization_id = p.organization_id").
+		Joins("JOIN organizations AS o ON o.id = p.organization_id").
+		Where("m.user_id = ? AND m.status = ? AND m.deleted_at IS NULL AND o.deleted_at IS NULL AND p.enabled = ?", userID, "active", true).
+		Scan(&rows).Error
+	if err != nil {
+		// Don't cache lookup failures; the next request retries
+		return nil
+	}
+	policies := make([]memberNetworkPolicy, 0, len(rows))
+	for _, row := range rows {
+		policies = append(policies, memberNetworkPolicy{policy: row.NetworkPolicy, orgName: row.OrgName})
+	}
+	s.cache.Store(userID, &cachedNetworkPolicies{policies: policies, expires: now.Add(networkPolicyCacheTTL)})
+	return policies
+}
+
+func (s *NetworkPolicyService) country(r *http.Request, ip net.IP) string {
+	if s.countries == nil || r == nil {
+		return ""
+	}
+	return s.countries.Country(r, ip)
+}
+
+// auditAccess records a denial or break-glass bypass, at most once per
+// interval for the same user, organization and address
+func (s *NetworkPolicyService) auditAccess(action string, userID *uint, denial *NetworkDenial, r *http.Request, description, severity, outcome string) {
+	if s.audit == nil {
+		return
+	}
+	key := fmt.Sprintf("%s:%d:%s", action, denial.OrganizationID, denial.IPAddress)
+	if userID != nil {
+		key += ":" + strconv.FormatUint(uint64(*userID), 10)
+	}
+	now := s.now()
+	if last, ok := s.audited.Load(key); ok && now.Sub(last.(time.Time)) < networkAuditInterval {
+		return
+	}
+	s.audited.Store(key, now)
+
+	orgID := denial.OrganizationID
+	s.audit.LogEvent(&AuditLog{
+		OrganizationID: &orgID,
+		UserID:         userID,
+		IPAddress:      denial.IPAddress,
+		UserAgent:      r.UserAgent(),
+		Action:         action,
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(uint64(orgID), 10),
+		ResourceName:   denial.OrganizationName,
+		Category:       "security",
+		Severity:       severity,
+		Outcome:        outcome,
+		Description:    description,
+		Metadata: map[string]interface{}{
+			"reason":  denial.Reason,
+			"country": denial.Country,
+			"path":    r.URL.Path,
+		},
+	})
+}
+
+// evaluate returns why the policy refuses an address, or "" when it
+// allows it
+func (p *NetworkPolicy) evaluate(ip net.IP, country string) string {
+	if !p.Enabled {
+		return ""
+	}
+	if country != "" && containsString(p.BlockedCountries, country) {
+		return NetworkDeniedBlockedCountry
+	}
+	if len(p.AllowedCIDRs) == 0 && len(p.AllowedCountries) == 0 {
+		if len(p.BlockedCountries) > 0 && country == "" {
+			return NetworkDeniedUnknownCountry
+		}
+		return ""
+	}
+	if ip != nil {
+		for _, entry := range p.AllowedCIDRs {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return ""
+			}
+		}
+	}
+	if len(p.AllowedCountries) > 0 {
+		if country == "" {
+			if len(p.AllowedCIDRs) == 0 {
+				return NetworkDeniedUnknownCountry
+			}
+			return NetworkDeniedIP
+		}
+		if containsString(p.AllowedCountries, country) {
+			return ""
+		}
+		if len(p.AllowedCIDRs) == 0 {
+			return NetworkDeniedCountry
+		}
+	}
+	return NetworkDeniedIP
+}
+
+func (p *NetworkPolicy) auditValue() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":              p.Enabled,
+		"enforce_api":          p.EnforceAPI,
+		"enforce_sso":          p.EnforceSSO,
+		"allowed_cidrs":        p.AllowedCIDRs,
+		"allowed_countries":    p.AllowedCountries,
+		"blocked_countries":    p.BlockedCountries,
+		"break_glass_user_ids": p.BreakGlassUserIDs,
+	}
+}
+
+// normalizeCIDR accepts a CIDR or a bare address, which becomes a /32 or
+// /128
+func normalizeCIDR(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return "", fmt.Errorf("%w: %q is not an IP address or CIDR", ErrInvalidNetworkPolicy, entry)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not a valid CIDR", ErrInvalidNetworkPolicy, entry)
+	}
+	if ones, _ := network.Mask.Size(); ones == 0 {
+		return "", fmt.Errorf("%w: %q allows every address", ErrInvalidNetworkPolicy, entry)
+	}
+	return network.String(), nil
+}
+
+func normalizeCountries(field string, countries []string) ([]string, error) {
+	normalized := make([]string, 0, len(countries))
+	for _, country := range countries {
+		code := strings.ToUpper(strings.TrimSpace(country))
+		if !isCountryCode(code) {
+			return nil, fmt.Errorf("%w: %s entry %q is not an ISO 3166-1 alpha-2 country code", ErrInvalidNetworkPolicy, field, country)
+		}
+		normalized = append(normalized, code)
+	}
+	return dedupeStrings(normalized), nil
+}
+
+func isCountryCode(code string) bool {
+	return len(code) == 2 && code[0] >= 'A' && code[0] <= 'Z' && code[1] >= 'A' && code[1] <= 'Z'
+}
+
+func dedupeStrings(values []string) []string {
+	sort.Strings(values)
+	out := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUint(values []uint, value uint) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package enterprise
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupNetworkPolicyTest(t *testing.T) (*NetworkPolicyService, *gorm.DB, *Organization) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Organization{}, &Role{}, &OrganizationMember{}, &AuditLog{}, &NetworkPolicy{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	org := &Organization{Name: "Acme", Slug: "acme"}
+	if err := db.Create(org).Error; err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+	for _, userID := range []uint{1, 2} {
+		if err := db.Create(&OrganizationMember{OrganizationID: org.ID, UserID: userID, RoleID: 1, Status: "active"}).Error; err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+	}
+	return NewNetworkPolicyService(db, NewAuditService(db)), db, org
+}
+
+func TestNetworkPolicyEvaluate(t *testing.T) {
+	policy := &NetworkPolicy{
+		Enabled:          true,
+		AllowedCIDRs:     []string{"10.0.0.0/8"},
+		AllowedCountries: []string{"DE"},
+		BlockedCountries: []string{"KP"},
+	}
+	cases := []struct {
+		ip, country, want string
+	}{
+		{"10.1.2.3", "", ""},
+		{"203.0.113.9", "DE", ""},
+		{"203.0.113.9", "FR", NetworkDeniedIP},
+		{"203.0.113.9", "", NetworkDeniedIP},
+		{"10.1.2.3", "KP", NetworkDeniedBlockedCountry},
+	}
+	for _, tc := range cases {
+		if got := policy.evaluate(net.ParseIP(tc.ip), tc.country); got != tc.want {
+			t.Errorf("evaluate(%s, %q) = %q, want %q", tc.ip, tc.country, got, tc.want)
+		}
+	}
+
+	countriesOnly := &NetworkPolicy{Enabled: true, AllowedCountries: []string{"DE"}}
+	if got := countriesOnly.evaluate(net.ParseIP("203.0.113.9"), "FR"); got != NetworkDeniedCountry {
+		t.Errorf("countries only, FR = %q", got)
+	}
+	if got := countriesOnly.evaluate(net.ParseIP("203.0.113.9"), ""); got != NetworkDeniedUnknownCountry {
+		t.Errorf("countries only, unknown = %q", got)
+	}
+	if got := (&NetworkPolicy{AllowedCIDRs: []string{"10.0.0.0/8"}}).evaluate(net.ParseIP("1.1.1.1"), ""); got != "" {
+		t.Errorf("disabled policy refused a request: %q", got)
+	}
+}
+
+func TestNetworkPolicySaveValidatesAndPreventsLockout(t *testing.T) {
+	svc, db, org := setupNetworkPolicyTest(t)
+	req := httptest.NewRequest("PUT", "/api/v1/enterprise/organizations/1/network-policy", nil)
+
+	for name, policy := range map[string]*NetworkPolicy{
+		"cidr":       {Enabled: true, AllowedCIDRs: []string{"10.0.0.0/33"}},
+		"everything": {Enabled: true, AllowedCIDRs: []string{"0.0.0.0/0"}},
+		"country":    {Enabled: true, AllowedCountries: []string{"Germany"}},
+		"empty":      {Enabled: true},
+		"outsider":   {Enabled: true, AllowedCIDRs: []string{"10.0.0.0/8"}, BreakGlassUserIDs: []uint{99}},
+	} {
+		if _, err := svc.Save(org.ID, 1, policy, req, "10.0.0.5"); !errors.Is(err, ErrInvalidNetworkPolicy) {
+			t.Errorf("%s: Save() = %v, want ErrInvalidNetworkPolicy", name, err)
+		}
+	}
+
+	lockout := &NetworkPolicy{Enabled: true, EnforceAPI: true, AllowedCIDRs: []string{"10.0.0.0/8"}}
+	if _, err := svc.Save(org.ID, 1, lockout, req, "203.0.113.9"); !errors.Is(err, ErrNetworkPolicyLockout) {
+		t.Fatalf("Save() from outside the allowlist = %v, want ErrNetworkPolicyLockout", err)
+	}
+
+	saved, err := svc.Save(org.ID, 1, &NetworkPolicy{
+		Enabled:           true,
+		EnforceAPI:        true,
+		AllowedCIDRs:      []string{"10.0.0.0/8", " 192.0.2.7 ", "10.0.0.0/8"},
+		BreakGlassUserIDs: []uint{1},
+	}, req, "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Save() with break-glass admin: %v", err)
+	}
+	if len(saved.AllowedCIDRs) != 2 || saved.AllowedCIDRs[1] != "192.0.2.7/32" {
+		t.Fatalf("allowed_cidrs = %v", saved.AllowedCIDRs)
+	}
+
+	var audits int64
+	db.Model(&AuditLog{}).Where("action = ? AND organization_id = ?", "network_policy_updated", org.ID).Count(&audits)
+	if audits != 1 {
+		t.Fatalf("network_policy_updated audit entries = %d, want 1", audits)
+	}
+}
+
+func TestNetworkPolicyEnforcement(t *testing.T) {
+	svc, db, org := setupNetworkPolicyTest(t)
+	req := httptest.NewRequest("GET", "/api/v1/projects", nil)
+	req.Header.Set("CF-IPCountry", "fr")
+
+	if _, err := svc.Save(org.ID, 1, &NetworkPolicy{
+		Enabled:           true,
+		EnforceAPI:        true,
+		EnforceSSO:        true,
+		AllowedCIDRs:      []string{"10.0.0.0/8"},
+		BlockedCountries:  []string{"KP"},
+		BreakGlassUserIDs: []uint{1},
+	}, req, "10.0.0.1"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if denial := svc.CheckAPI(2, req, "10.9.9.9"); denial != nil {
+		t.Fatalf("allowed address refused: %+v", denial)
+	}
+	denial := svc.CheckAPI(2, req, "203.0.113.9")
+	if denial == nil || denial.Reason != NetworkDeniedIP || denial.Country != "FR" || denial.OrganizationName != "Acme" {
+		t.Fatalf("denial = %+v", denial)
+	}
+	if denial := svc.CheckAPI(1, req, "203.0.113.9"); denial != nil {
+		t.Fatalf("break-glass admin refused: %+v", denial)
+	}
+	if denial := svc.CheckAPI(3, req, "203.0.113.9"); denial != nil {
+		t.Fatalf("non-member refused: %+v", denial)
+	}
+	if denial := svc.CheckSSO(org, req, "203.0.113.9"); denial == nil {
+		t.Fatal("SSO from outside the allowlist was not refused")
+	}
+
+	var denied, bypassed int64
+	db.Model(&AuditLog{}).Where("action = ?", "network_policy_denied").Count(&denied)
+	db.Model(&AuditLog{}).Where("action = ?", "network_policy_break_glass").Count(&bypassed)
+	if denied != 2 || bypassed != 1 {
+		t.Fatalf("audit entries: denied=%d break_glass=%d, want 2 and 1", denied, bypassed)
+	}
+
+	// Repeated denials from the same address are not audited again
+	svc.CheckAPI(2, req, "203.0.113.9")
+	db.Model(&AuditLog{}).Where("action = ?", "network_policy_denied").Count(&denied)
+	if denied != 2 {
+		t.Fatalf("repeated denial audited again: %d entries", denied)
+	}
+}
@@ -0,0 +1,317 @@
+// Package envcheck compares the environment variables a project's code reads
+// with the variables configured for each of its environments, so builds can
+// report what is missing before the app fails at runtime.
+package envcheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/internal/envlock"
+	"apex-build/internal/pipeline"
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// TierWorkspace holds the variables previews and runs receive: the
+// environment config's variables and the environment secrets. Pipeline
+// environments are checked as further tiers under their own names.
+const TierWorkspace = "workspace"
+
+// Suggested fixes for a missing variable.
+const (
+	// ActionCreatePlaceholder creates an empty environment secret for the
+	// owner to fill in
+	ActionCreatePlaceholder = "create_placeholder_secret"
+	// ActionSetSecretValue means only a placeholder secret exists
+	ActionSetSecretValue = "set_secret_value"
+	// ActionSetEnvironmentVariable means a pipeline environment lacks the
+	// variable the workspace has
+	ActionSetEnvironmentVariable = "set_environment_variable"
+)
+
+// maxReferencedFiles caps the files listed for each variable.
+const maxReferencedFiles = 5
+
+// ErrProjectNotFound is returned when the project does not exist
+var ErrProjectNotFound = errors.New("project not found")
+
+// platformEnvVars are set by the platform for every process.
+var platformEnvVars = map[string]bool{
+	"PORT":                      true,
+	"HOST":                      true,
+	secrets.ProjectTokenEnvVar:  true,
+	secrets.ProjectIDEnvVar:     true,
+	secrets.ProjectAPIURLEnvVar: true,
+}
+
+// Missing is a variable the code reads that an environment lacks.
+type Missing struct {
+	Name string `json:"name"`
+	// Files are the first files that read the variable
+	Files []string `json:"files"`
+	// MissingIn lists the tiers without the variable
+	MissingIn []string `json:"missing_in"`
+	// Placeholder is set when the workspace only has an empty placeholder
+	Placeholder bool   `json:"placeholder,omitempty"`
+	Action      string `json:"action"`
+	Message     string `json:"message"`
+}
+
+// Report is the result of checking code against a project's environments.
+type Report struct {
+	// Required lists every variable the code reads, sorted
+	Required []string `json:"required"`
+	// Tiers lists the environments checked, workspace first
+	Tiers     []string  `json:"tiers"`
+	Missing   []Missing `json:"missing"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Warning is a one-line summary when variables are missing
+	Warning string `json:"warning,omitempty"`
+}
+
+// Checker checks code against the configured environments and creates
+// placeholder secrets for missing variables.
+type Checker struct {
+	db      *gorm.DB
+	secrets *secrets.SecretsManager
+}
+
+// NewChecker creates a Checker. The secrets manager is only needed to
+// create placeholders.
+func NewChecker(db *gorm.DB, sm *secrets.SecretsManager) *Checker {
+	return &Checker{db: db, secrets: sm}
+}
+
+// Check compares files with the environments of a project. A zero
+// projectID checks against the user's account-wide secrets only, as for a
+// build that has no project yet.
+func (c *Checker) Check(userID, projectID uint, files map[string]string) (*Report, error) {
+	refs := envlock.ExtractEnvVarReferences(files)
+	report := &Report{Required: []string{}, Tiers: []string{TierWorkspace}, Missing: []Missing{}, CheckedAt: time.Now().UTC()}
+	for name := range refs {
+		if !platformEnvVars[name] {
+			report.Required = append(report.Required, name)
+		}
+	}
+	sort.Strings(report.Required)
+	if len(report.Required) == 0 {
+		return report, nil
+	}
+
+	workspace, placeholders, err := c.workspaceEnv(userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	tiers := map[string]map[string]bool{TierWorkspace: workspace}
+	if projectID != 0 {
+		var environments []pipeline.Environment
+		if err := c.db.Where("project_id = ?", projectID).Find(&environments).Error; err != nil {
+			return nil, err
+		}
+		byName := make(map[string]pipeline.Environment, len(environments))
+		for _, env := range environments {
+			byName[env.Name] = env
+		}
+		for _, name := range []string{pipeline.EnvDevelopment, pipeline.EnvStaging, pipeline.EnvProduction} {
+			env, ok := byName[name]
+			if !ok {
+				continue
+			}
+			keys := make(map[string]bool, len(env.EnvVarKeys))
+			for _, key := range env.EnvVarKeys {
+				keys[key] = true
+			}
+			tiers[name] = keys
+			report.Tiers = append(report.Tiers, name)
+		}
+	}
+
+	for _, name := range report.Required {
+		var missingIn []string
+		for _, tier := range report.Tiers {
+			if !tiers[tier][name] {
+				missingIn = append(missingIn, tier)
+			}
+		}
+		placeholder := placeholders[name]
+		if len(missingIn) == 0 && !placeholder {
+			continue
+		}
+		files := refs[name]
+		if len(files) > maxReferencedFiles {
+			files = files[:maxReferencedFiles]
+		}
+		missing := Missing{Name: name, Files: files, MissingIn: missingIn, Placeholder: placeholder}
+		switch {
+		case len(missingIn) > 0 && missingIn[0] == TierWorkspace:
+			missing.Action = ActionCreatePlaceholder
+			missing.Message = fmt.Sprintf("%s is read by %s but not configured; add it as a secret", name, files[0])
+		case placeholder:
+			missing.Action = ActionSetSecretValue
+			missing.Message = fmt.Sprintf("%s is a placeholder secret; set its value", name)
+		default:
+			missing.Action = ActionSetEnvironmentVariable
+			missing.Message = fmt.Sprintf("%s is not set in the %s environment", name, strings.Join(missingIn, " and "))
+		}
+		report.Missing = append(report.Missing, missing)
+	}
+	report.Warning = Summary(report.Missing)
+	return report, nil
+}
+
+// CheckProject checks the project's stored files against its environments.
+func (c *Checker) CheckProject(projectID uint) (*Report, error) {
+	var files []models.File
+	if err := c.db.Select("path", "content").
+		Where("project_id = ? AND type <> ? AND is_binary = ?", projectID, "directory", false).
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+	contents := make(map[string]string, len(files))
+	for _, f := range files {
+		contents[f.Path] = f.Content
+	}
+	return c.Check(0, projectID, contents)
+}
+
+// workspaceEnv returns the variable names previews and runs receive, and
+// the names only set by placeholder secrets.
+func (c *Checker) workspaceEnv(userID, projectID uint) (map[string]bool, map[string]bool, error) {
+	names := map[string]bool{}
+	placeholders := map[string]bool{}
+	query := c.db.Select("name", "is_placeholder", "project_id").Where("type = ?", secrets.SecretTypeEnvironment)
+	if projectID != 0 {
+		var project models.Project
+		if err := c.db.Select("id", "owner_id", "environment").First(&project, projectID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, ErrProjectNotFound
+			}
+			return nil, nil, err
+		}
+		for name := range configEnvVars(&project) {
+			names[name] = true
+		}
+		query = query.Where("user_id = ? AND (project_id = ? OR project_id IS NULL)", project.OwnerID, project.ID)
+	} else {
+		query = query.Where("user_id = ? AND project_id IS NULL", userID)
+	}
+
+	var secretList []secrets.Secret
+	if err := query.Find(&secretList).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, secret := range secretList {
+		if secret.IsPlaceholder {
+			if !names[secret.Name] {
+				placeholders[secret.Name] = true
+			}
+			continue
+		}
+		names[secret.Name] = true
+		delete(placeholders, secret.Name)
+	}
+	// A placeholder still counts as configured in the workspace
+	for name := range placeholders {
+		names[name] = true
+	}
+	return names, placeholders, nil
+}
+
+// configEnvVars returns the non-secret variables of the project's
+// environment config.
+func configEnvVars(project *models.Project) map[string]string {
+	raw, ok := project.Environment["config"].(string)
+	if !ok {
+		return nil
+	}
+	var config struct {
+		EnvVars map[string]string `json:"env_vars"`
+	}
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return nil
+	}
+	return config.EnvVars
+}
+
+// CreatePlaceholders creates an empty project environment secret for each
+// name that has no environment secret yet, and returns the ones created.
+func (c *Checker) CreatePlaceholders(projectID, ownerID uint, names []string) ([]secrets.SecretMetadata, error) {
+	if c.secrets == nil {
+		return nil, errors.New("secrets manager is not configured")
+	}
+	var existing []string
+	if err := c.db.Model(&secrets.Secret{}).
+		Where("user_id = ? AND (project_id = ? OR project_id IS NULL) AND type = ?", ownerID, projectID, secrets.SecretTypeEnvironment).
+		Pluck("name", &existing).Error; err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		have[name] = true
+	}
+
+	created := []secrets.SecretMetadata{}
+	now := time.Now()
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			if have[name] {
+				continue
+			}
+			have[name] = true
+			encrypted, salt, fingerprint, err := c.secrets.Encrypt(ownerID, "")
+			if err != nil {
+				return fmt.Errorf("encrypt %s: %w", name, err)
+			}
+			pid := projectID
+			secret := secrets.Secret{
+				UserID:         ownerID,
+				ProjectID:      &pid,
+				Name:           name,
+				Description:    "Read by the generated code " + secrets.PlaceholderNote,
+				Type:           secrets.SecretTypeEnvironment,
+				IsPlaceholder:  true,
+				EncryptedValue: encrypted,
+				Salt:           salt,
+				KeyFingerprint: fingerprint,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+			if err := tx.Create(&secret).Error; err != nil {
+				return err
+			}
+			created = append(created, secret.ToMetadata())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Summary is a one-line description of missing variables, or "" when none
+// are missing.
+func Summary(missing []Missing) string {
+	if len(missing) == 0 {
+		return ""
+	}
+	names := make([]string, 0, 3)
+	for i := 0; i < len(missing) && i < 3; i++ {
+		names = append(names, missing[i].Name)
+	}
+	detail := strings.Join(names, ", ")
+	if len(missing) > 3 {
+		detail = fmt.Sprintf("%s and %d more", detail, len(missing)-3)
+	}
+	if len(missing) == 1 {
+		return "environment variable not configured: " + detail
+	}
+	return fmt.Sprintf("%d environment variables not configured: %s", len(missing), detail)
+}
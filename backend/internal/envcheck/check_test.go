@@ -0,0 +1,116 @@
+package envcheck
+
+import (
+	"testing"
+
+	"apex-build/internal/pipeline"
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupChecker(t *testing.T) (*Checker, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Project{}, &models.File{}, &secrets.Secret{}, &pipeline.Environment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	key, _ := secrets.GenerateMasterKey()
+	sm, _ := secrets.NewSecretsManager(key)
+	return NewChecker(db, sm), db
+}
+
+func TestCheckReportsVariablesMissingPerTier(t *testing.T) {
+	checker, db := setupChecker(t)
+	project := models.Project{
+		Name:        "shop",
+		OwnerID:     2,
+		Language:    "javascript",
+		Environment: map[string]interface{}{"config": `{"env_vars":{"API_BASE":"/api"}}`},
+	}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&secrets.Secret{UserID: 2, ProjectID: &project.ID, Name: "DATABASE_URL", Type: secrets.SecretTypeEnvironment, EncryptedValue: "x", KeyFingerprint: "f", Salt: "s"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&secrets.Secret{UserID: 2, ProjectID: &project.ID, Name: "STRIPE_KEY", Description: "Payments " + secrets.PlaceholderNote, Type: secrets.SecretTypeEnvironment, IsPlaceholder: true, EncryptedValue: "x", KeyFingerprint: "f", Salt: "s"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&pipeline.Environment{ProjectID: project.ID, Name: pipeline.EnvProduction, Target: "vercel", EnvVarKeys: []string{"DATABASE_URL", "STRIPE_KEY"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"server.js":    "connect(process.env.DATABASE_URL); listen(process.env.PORT)",
+		"pay.js":       "stripe(process.env.STRIPE_KEY)",
+		"src/api.ts":   "fetch(import.meta.env.API_BASE)",
+		"worker.py":    "import os\nos.environ['SENDGRID_API_KEY']",
+		"README.md":    "process.env.NOT_CODE",
+		".env.example": "DATABASE_URL=\n",
+	}
+	report, err := checker.Check(2, project.ID, files)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(report.Tiers) != 2 || report.Tiers[1] != pipeline.EnvProduction {
+		t.Fatalf("Tiers = %v", report.Tiers)
+	}
+	if len(report.Required) != 4 {
+		t.Fatalf("Required = %v, want the four non-platform variables", report.Required)
+	}
+
+	byName := map[string]Missing{}
+	for _, m := range report.Missing {
+		byName[m.Name] = m
+	}
+	if len(byName) != 3 {
+		t.Fatalf("Missing = %+v", report.Missing)
+	}
+	if m := byName["SENDGRID_API_KEY"]; m.Action != ActionCreatePlaceholder || len(m.MissingIn) != 2 || m.Files[0] != "worker.py" {
+		t.Fatalf("SENDGRID_API_KEY = %+v", m)
+	}
+	if m := byName["STRIPE_KEY"]; m.Action != ActionSetSecretValue || !m.Placeholder || len(m.MissingIn) != 0 {
+		t.Fatalf("STRIPE_KEY = %+v", m)
+	}
+	if m := byName["API_BASE"]; m.Action != ActionSetEnvironmentVariable || len(m.MissingIn) != 1 || m.MissingIn[0] != pipeline.EnvProduction {
+		t.Fatalf("API_BASE = %+v", m)
+	}
+	if report.Warning == "" {
+		t.Fatal("expected a warning")
+	}
+}
+
+func TestCreatePlaceholdersSkipsExistingSecrets(t *testing.T) {
+	checker, db := setupChecker(t)
+	project := models.Project{Name: "bot", OwnerID: 5, Language: "python"}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.File{ProjectID: project.ID, Path: "/bot.py", Name: "bot.py", Type: "file", Content: "os.getenv('BOT_TOKEN'); os.getenv('OPENAI_API_KEY')"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Account-wide secrets cover every project
+	if err := db.Create(&secrets.Secret{UserID: 5, Name: "OPENAI_API_KEY", Type: secrets.SecretTypeEnvironment, EncryptedValue: "x", KeyFingerprint: "f", Salt: "s"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := checker.CheckProject(project.ID)
+	if err != nil || len(report.Missing) != 1 || report.Missing[0].Name != "BOT_TOKEN" {
+		t.Fatalf("CheckProject() = %+v, %v", report, err)
+	}
+	created, err := checker.CreatePlaceholders(project.ID, 5, []string{"BOT_TOKEN", "OPENAI_API_KEY"})
+	if err != nil || len(created) != 1 || created[0].Name != "BOT_TOKEN" {
+		t.Fatalf("CreatePlaceholders() = %+v, %v", created, err)
+	}
+
+	report, err = checker.CheckProject(project.ID)
+	if err != nil || len(report.Missing) != 1 || report.Missing[0].Action != ActionSetSecretValue {
+		t.Fatalf("CheckProject() after placeholders = %+v, %v", report, err)
+	}
+}
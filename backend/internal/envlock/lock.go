@@ -0,0 +1,559 @@
+// Package envlock captures the environment a project was built against in
+// an apex.lock file at the project root: language runtime versions, system
+// packages, the names of the environment variables the code reads and the
+// backing services it expects. The lock is written when a build finishes or
+// a project is first previewed or executed, and later runs compare the
+// project against it so a platform or machine upgrade that changes a
+// runtime version shows up as drift instead of a mystery failure.
+package envlock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/internal/dockerize"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FileName is the lockfile at the project root.
+	FileName = "apex.lock"
+	// LockfileVersion is the format version written into new locks.
+	LockfileVersion = 1
+	// MaxLockBytes caps apex.lock.
+	MaxLockBytes = 256 * 1024
+	// maxScanBytes skips source files too large to be hand-written code.
+	maxScanBytes = 512 * 1024
+)
+
+// Sources a lock is captured from.
+const (
+	SourceBuild     = "build"
+	SourcePreview   = "preview"
+	SourceExecution = "execution"
+)
+
+// SourcePlatformDefault marks a runtime the project doesn't pin, recorded at
+// the version the platform currently provides.
+const SourcePlatformDefault = "platform_default"
+
+// Drift kinds, one per section of the lock.
+const (
+	DriftLanguage      = "language"
+	DriftSystemPackage = "system_package"
+	DriftEnvVar        = "env_var"
+	DriftService       = "service"
+)
+
+// Drift changes.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// ErrInvalidLock is returned when apex.lock cannot be used.
+var ErrInvalidLock = errors.New("invalid apex.lock")
+
+// lockFilePaths are the stored paths that count as the root apex.lock.
+var lockFilePaths = []string{FileName, "/" + FileName, "./" + FileName}
+
+// Language is a runtime or toolchain version.
+type Language struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Source is the file the version was read from, or platform_default.
+	Source string `json:"source"`
+}
+
+// SystemPackage is an OS package installed by the project's Dockerfile or
+// Aptfile.
+type SystemPackage struct {
+	Manager string `json:"manager"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// ServiceDependency is a backing service the project depends on.
+type ServiceDependency struct {
+	Name   string `json:"name"`
+	Image  string `json:"image,omitempty"`
+	Source string `json:"source"`
+}
+
+// Lock is the content of apex.lock.
+type Lock struct {
+	Version        int             `json:"lockfile_version"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	GeneratedBy    string          `json:"generated_by"`
+	Languages      []Language      `json:"languages"`
+	SystemPackages []SystemPackage `json:"system_packages"`
+	// EnvVars are names only; values never enter the lock.
+	EnvVars  []string            `json:"env_vars"`
+	Services []ServiceDependency `json:"services"`
+}
+
+// Drift is one difference between a lock and the project's current state.
+type Drift struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Change  string `json:"change"`
+	Locked  string `json:"locked,omitempty"`
+	Current string `json:"current,omitempty"`
+}
+
+// Capture builds a lock from the project's files, keyed by path.
+func Capture(files map[string]string, source string) *Lock {
+	normalized := make(map[string]string, len(files))
+	for name, content := range files {
+		normalized[normalizePath(name)] = content
+	}
+	return &Lock{
+		Version:        LockfileVersion,
+		GeneratedAt:    time.Now().UTC(),
+		GeneratedBy:    source,
+		Languages:      captureLanguages(normalized),
+		SystemPackages: captureSystemPackages(normalized),
+		EnvVars:        ExtractEnvVarNames(normalized),
+		Services:       captureServices(normalized),
+	}
+}
+
+// Find returns the content of the root apex.lock in files, if any.
+func Find(files map[string]string) (string, bool) {
+	for _, p := range lockFilePaths {
+		if content, ok := files[p]; ok {
+			return content, true
+		}
+	}
+	return "", false
+}
+
+// IsLockPath reports whether a stored path is the root apex.lock.
+func IsLockPath(p string) bool {
+	for _, candidate := range lockFilePaths {
+		if p == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads and validates apex.lock.
+func Parse(content string) (*Lock, error) {
+	if len(content) > MaxLockBytes {
+		return nil, fmt.Errorf("%w: file exceeds %d bytes", ErrInvalidLock, MaxLockBytes)
+	}
+	var lock Lock
+	if err := json.Unmarshal([]byte(content), &lock); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLock, err)
+	}
+	if lock.Version < 1 || lock.Version > LockfileVersion {
+		return nil, fmt.Errorf("%w: unsupported lockfile_version %d", ErrInvalidLock, lock.Version)
+	}
+	return &lock, nil
+}
+
+// Encode renders the lock as indented JSON with a trailing newline.
+func (l *Lock) Encode() (string, error) {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Compare lists what changed between locked and current. Generation
+// metadata is ignored.
+func Compare(locked, current *Lock) []Drift {
+	if locked == nil || current == nil {
+		return nil
+	}
+	drift := []Drift{}
+
+	lockedLanguages := map[string]string{}
+	for _, l := range locked.Languages {
+		lockedLanguages[l.Name] = l.Version
+	}
+	currentLanguages := map[string]string{}
+	for _, l := range current.Languages {
+		currentLanguages[l.Name] = l.Version
+	}
+	drift = append(drift, compareMaps(DriftLanguage, lockedLanguages, currentLanguages)...)
+
+	lockedPackages := map[string]string{}
+	for _, p := range locked.SystemPackages {
+		lockedPackages[p.Manager+":"+p.Name] = p.Version
+	}
+	currentPackages := map[string]string{}
+	for _, p := range current.SystemPackages {
+		currentPackages[p.Manager+":"+p.Name] = p.Version
+	}
+	drift = append(drift, compareMaps(DriftSystemPackage, lockedPackages, currentPackages)...)
+
+	lockedEnv := map[string]string{}
+	for _, name := range locked.EnvVars {
+		lockedEnv[name] = ""
+	}
+	currentEnv := map[string]string{}
+	for _, name := range current.EnvVars {
+		currentEnv[name] = ""
+	}
+	drift = append(drift, compareMaps(DriftEnvVar, lockedEnv, currentEnv)...)
+
+	lockedServices := map[string]string{}
+	for _, s := range locked.Services {
+		lockedServices[s.Name] = s.Image
+	}
+	currentServices := map[string]string{}
+	for _, s := range current.Services {
+		currentServices[s.Name] = s.Image
+	}
+	drift = append(drift, compareMaps(DriftService, lockedServices, currentServices)...)
+
+	return drift
+}
+
+func compareMaps(kind string, locked, current map[string]string) []Drift {
+	var drift []Drift
+	for name, version := range locked {
+		now, ok := current[name]
+		switch {
+		case !ok:
+			drift = append(drift, Drift{Kind: kind, Name: name, Change: ChangeRemoved, Locked: version})
+		case now != version:
+			drift = append(drift, Drift{Kind: kind, Name: name, Change: ChangeChanged, Locked: version, Current: now})
+		}
+	}
+	for name, version := range current {
+		if _, ok := locked[name]; !ok {
+			drift = append(drift, Drift{Kind: kind, Name: name, Change: ChangeAdded, Current: version})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Name < drift[j].Name })
+	return drift
+}
+
+func normalizePath(p string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(p, "./"), "/")
+}
+
+type packageJSON struct {
+	Engines struct {
+		Node string `json:"node"`
+	} `json:"engines"`
+	PackageManager  string            `json:"packageManager"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+func parsePackageJSON(files map[string]string) *packageJSON {
+	content, ok := files["package.json"]
+	if !ok {
+		return nil
+	}
+	var pkg packageJSON
+	if err := json.Unmarshal([]byte(content), &pkg); err != nil {
+		return &packageJSON{}
+	}
+	return &pkg
+}
+
+var (
+	requiresPythonPattern = regexp.MustCompile(`(?m)^\s*requires-python\s*=\s*["']([^"']+)["']`)
+	goDirectivePattern    = regexp.MustCompile(`(?m)^go\s+(\S+)`)
+	toolchainPattern      = regexp.MustCompile(`(?m)^toolchain\s+go(\S+)`)
+	rustChannelPattern    = regexp.MustCompile(`(?m)^\s*channel\s*=\s*["']([^"']+)["']`)
+)
+
+// firstPinned returns the first non-empty trimmed line of the first file
+// present, with the file it came from.
+func firstPinned(files map[string]string, names ...string) (string, string) {
+	for _, name := range names {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		for _, line := range strings.Split(content, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				return line, name
+			}
+		}
+	}
+	return "", ""
+}
+
+func captureLanguages(files map[string]string) []Language {
+	var languages []Language
+	if pkg := parsePackageJSON(files); pkg != nil {
+		node := Language{Name: "node", Version: dockerize.DefaultNodeVersion, Source: SourcePlatformDefault}
+		if version, source := firstPinned(files, ".nvmrc", ".node-version"); version != "" {
+			node.Version, node.Source = strings.TrimPrefix(version, "v"), source
+		} else if pkg.Engines.Node != "" {
+			node.Version, node.Source = pkg.Engines.Node, "package.json"
+		}
+		languages = append(languages, node)
+		if name, version, ok := strings.Cut(pkg.PackageManager, "@"); ok && name != "" {
+			// Strip the corepack integrity hash
+			version, _, _ = strings.Cut(version, "+")
+			languages = append(languages, Language{Name: name, Version: version, Source: "package.json"})
+		}
+	}
+
+	if hasAny(files, "requirements.txt", "pyproject.toml", "Pipfile", "runtime.txt", ".python-version") {
+		python := Language{Name: "python", Version: dockerize.DefaultPythonVersion, Source: SourcePlatformDefault}
+		if version, source := firstPinned(files, ".python-version"); version != "" {
+			python.Version, python.Source = version, source
+		} else if version, source := firstPinned(files, "runtime.txt"); version != "" {
+			python.Version, python.Source = strings.TrimPrefix(version, "python-"), source
+		} else if m := requiresPythonPattern.FindStringSubmatch(files["pyproject.toml"]); m != nil {
+			python.Version, python.Source = m[1], "pyproject.toml"
+		}
+		languages = append(languages, python)
+	}
+
+	if gomod, ok := files["go.mod"]; ok {
+		goLang := Language{Name: "go", Version: dockerize.DefaultGoVersion, Source: SourcePlatformDefault}
+		if m := toolchainPattern.FindStringSubmatch(gomod); m != nil {
+			goLang.Version, goLang.Source = m[1], "go.mod"
+		} else if m := goDirectivePattern.FindStringSubmatch(gomod); m != nil {
+			goLang.Version, goLang.Source = m[1], "go.mod"
+		}
+		languages = append(languages, goLang)
+	}
+
+	if _, ok := files["Cargo.toml"]; ok {
+		// The platform builds Rust on the rolling stable channel
+		rust := Language{Name: "rust", Version: "stable", Source: SourcePlatformDefault}
+		if m := rustChannelPattern.FindStringSubmatch(files["rust-toolchain.toml"]); m != nil {
+			rust.Version, rust.Source = m[1], "rust-toolchain.toml"
+		} else if version, source := firstPinned(files, "rust-toolchain"); version != "" {
+			rust.Version, rust.Source = version, source
+		}
+		languages = append(languages, rust)
+	}
+	return languages
+}
+
+func hasAny(files map[string]string, names ...string) bool {
+	for _, name := range names {
+		if _, ok := files[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+var installCommandPattern = regexp.MustCompile(`(apt-get|apt|apk|yum|dnf)\s+(?:-\S+\s+)*(?:install|add)\s+([^&;|]+)`)
+
+func captureSystemPackages(files map[string]string) []SystemPackage {
+	seen := map[string]SystemPackage{}
+	add := func(manager, spec string) {
+		if spec == "" || strings.HasPrefix(spec, "-") || strings.ContainsAny(spec, "$\\") {
+			return
+		}
+		manager = strings.TrimSuffix(manager, "-get")
+		name, version := spec, ""
+		if manager == "apt" || manager == "apk" {
+			name, version, _ = strings.Cut(spec, "=")
+		}
+		seen[manager+":"+name] = SystemPackage{Manager: manager, Name: name, Version: version}
+	}
+
+	for name, content := range files {
+		if path.Base(name) != "Dockerfile" && !strings.HasPrefix(path.Base(name), "Dockerfile.") {
+			continue
+		}
+		// Fold line continuations so a multi-line RUN reads as one command
+		content = strings.ReplaceAll(content, "\\\n", " ")
+		for _, m := range installCommandPattern.FindAllStringSubmatch(content, -1) {
+			for _, spec := range strings.Fields(m[2]) {
+				add(m[1], spec)
+			}
+		}
+	}
+	if aptfile, ok := files["Aptfile"]; ok {
+		for _, line := range strings.Split(aptfile, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				add("apt", line)
+			}
+		}
+	}
+
+	packages := make([]SystemPackage, 0, len(seen))
+	for _, p := range seen {
+		packages = append(packages, p)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Manager != packages[j].Manager {
+			return packages[i].Manager < packages[j].Manager
+		}
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+var (
+	envReadPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`process\.env\.([A-Z_][A-Z0-9_]*)`),
+		regexp.MustCompile(`process\.env\[\s*["']([A-Z_][A-Z0-9_]*)["']\s*\]`),
+		regexp.MustCompile(`import\.meta\.env\.([A-Z_][A-Z0-9_]*)`),
+		regexp.MustCompile(`os\.(?:Getenv|LookupEnv)\(\s*"([A-Z_][A-Z0-9_]*)"`),
+		regexp.MustCompile(`os\.environ(?:\.get)?[\[(]\s*["']([A-Z_][A-Z0-9_]*)["']`),
+		regexp.MustCompile(`os\.getenv\(\s*["']([A-Z_][A-Z0-9_]*)["']`),
+		regexp.MustCompile(`env::var\(\s*"([A-Z_][A-Z0-9_]*)"`),
+	}
+	envFileKeyPattern = regexp.MustCompile(`(?m)^\s*(?:export\s+)?([A-Z_][A-Z0-9_]*)\s*=`)
+	sourceExtensions  = map[string]bool{
+		".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".cjs": true,
+		".vue": true, ".svelte": true, ".py": true, ".go": true, ".rs": true,
+	}
+	// ignoredEnvVars are set by every runtime and say nothing about the
+	// project's configuration.
+	ignoredEnvVars = map[string]bool{"NODE_ENV": true, "DEV": true, "PROD": true, "MODE": true, "SSR": true, "BASE_URL": true}
+)
+
+// ExtractEnvVarNames lists the environment variables the code reads or its
+// example env files declare, sorted. Values are never read.
+func ExtractEnvVarNames(files map[string]string) []string {
+	refs := ExtractEnvVarReferences(files)
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExtractEnvVarReferences maps each environment variable the code reads or
+// its example env files declare to the sorted paths that mention it.
+func ExtractEnvVarReferences(files map[string]string) map[string][]string {
+	seen := map[string]map[string]bool{}
+	add := func(name, file string) {
+		if ignoredEnvVars[name] {
+			return
+		}
+		if seen[name] == nil {
+			seen[name] = map[string]bool{}
+		}
+		seen[name][file] = true
+	}
+	for name, content := range files {
+		name = normalizePath(name)
+		if strings.Contains(name, "node_modules/") || len(content) > maxScanBytes {
+			continue
+		}
+		base := path.Base(name)
+		if base == ".env.example" || base == ".env.sample" || base == ".env.template" {
+			for _, m := range envFileKeyPattern.FindAllStringSubmatch(content, -1) {
+				add(m[1], name)
+			}
+			continue
+		}
+		if !sourceExtensions[path.Ext(name)] {
+			continue
+		}
+		for _, pattern := range envReadPatterns {
+			for _, m := range pattern.FindAllStringSubmatch(content, -1) {
+				add(m[1], name)
+			}
+		}
+	}
+	refs := make(map[string][]string, len(seen))
+	for name, paths := range seen {
+		list := make([]string, 0, len(paths))
+		for p := range paths {
+			list = append(list, p)
+		}
+		sort.Strings(list)
+		refs[name] = list
+	}
+	return refs
+}
+
+// dependencyServices maps client libraries to the service they connect to.
+var dependencyServices = map[string]string{
+	"pg":          "postgres",
+	"postgres":    "postgres",
+	"psycopg2":    "postgres",
+	"psycopg":     "postgres",
+	"redis":       "redis",
+	"ioredis":     "redis",
+	"mongoose":    "mongodb",
+	"mongodb":     "mongodb",
+	"pymongo":     "mongodb",
+	"mysql":       "mysql",
+	"mysql2":      "mysql",
+	"amqplib":     "rabbitmq",
+	"pika":        "rabbitmq",
+	"kafkajs":     "kafka",
+	"typesense":   "typesense",
+	"meilisearch": "meilisearch",
+}
+
+var requirementNamePattern = regexp.MustCompile(`^([A-Za-z0-9_.\-]+)`)
+
+func captureServices(files map[string]string) []ServiceDependency {
+	services := map[string]ServiceDependency{}
+
+	for _, name := range []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		var doc struct {
+			Services map[string]struct {
+				Image string `yaml:"image"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+			continue
+		}
+		for serviceName, svc := range doc.Services {
+			// Services built from the project itself aren't dependencies
+			if svc.Image == "" {
+				continue
+			}
+			services[serviceName] = ServiceDependency{Name: serviceName, Image: svc.Image, Source: name}
+		}
+	}
+
+	addDependency := func(dep, source string) {
+		service, ok := dependencyServices[strings.ToLower(dep)]
+		if !ok {
+			return
+		}
+		for _, existing := range services {
+			if existing.Name == service || strings.HasPrefix(existing.Image, service) {
+				return
+			}
+		}
+		services[service] = ServiceDependency{Name: service, Source: source}
+	}
+	if pkg := parsePackageJSON(files); pkg != nil {
+		for dep := range pkg.Dependencies {
+			addDependency(dep, "package.json")
+		}
+	}
+	if requirements, ok := files["requirements.txt"]; ok {
+		for _, line := range strings.Split(requirements, "\n") {
+			if m := requirementNamePattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				addDependency(strings.TrimSuffix(m[1], "-binary"), "requirements.txt")
+			}
+		}
+	}
+
+	list := make([]ServiceDependency, 0, len(services))
+	for _, s := range services {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,159 @@
+package envlock
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"apex-build/internal/dockerize"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCaptureRecordsRuntimePackagesEnvAndServices(t *testing.T) {
+	lock := Capture(map[string]string{
+		"/package.json":      `{"engines":{"node":">=20"},"packageManager":"pnpm@9.1.0+sha512.abc","dependencies":{"pg":"8","ioredis":"5"}}`,
+		".nvmrc":             "v20.11.1\n",
+		"src/db.ts":          "const url = process.env.DATABASE_URL; const mode = process.env.NODE_ENV",
+		"src/app.tsx":        "fetch(import.meta.env.VITE_API_URL)",
+		".env.example":       "STRIPE_SECRET_KEY=\n# COMMENTED=1\n",
+		"Dockerfile":         "FROM node:20\nRUN apt-get update && apt-get install -y --no-install-recommends \\\n    curl ffmpeg=7:5.1 && rm -rf /var/lib/apt/lists/*\n",
+		"docker-compose.yml": "services:\n  app:\n    build: .\n  cache:\n    image: redis:7\n",
+	}, SourceBuild)
+
+	wantLanguages := []Language{
+		{Name: "node", Version: "20.11.1", Source: ".nvmrc"},
+		{Name: "pnpm", Version: "9.1.0", Source: "package.json"},
+	}
+	if !reflect.DeepEqual(lock.Languages, wantLanguages) {
+		t.Fatalf("languages = %+v", lock.Languages)
+	}
+	wantPackages := []SystemPackage{{Manager: "apt", Name: "curl"}, {Manager: "apt", Name: "ffmpeg", Version: "7:5.1"}}
+	if !reflect.DeepEqual(lock.SystemPackages, wantPackages) {
+		t.Fatalf("system packages = %+v", lock.SystemPackages)
+	}
+	if want := []string{"DATABASE_URL", "STRIPE_SECRET_KEY", "VITE_API_URL"}; !reflect.DeepEqual(lock.EnvVars, want) {
+		t.Fatalf("env vars = %v", lock.EnvVars)
+	}
+	// redis comes from compose, so ioredis doesn't add a second entry
+	wantServices := []ServiceDependency{
+		{Name: "cache", Image: "redis:7", Source: "docker-compose.yml"},
+		{Name: "postgres", Source: "package.json"},
+	}
+	if !reflect.DeepEqual(lock.Services, wantServices) {
+		t.Fatalf("services = %+v", lock.Services)
+	}
+}
+
+func TestCaptureFallsBackToPlatformDefaults(t *testing.T) {
+	lock := Capture(map[string]string{
+		"requirements.txt": "flask==3.0\npsycopg2-binary==2.9\n",
+		"app.py":           "import os\nkey = os.environ['API_KEY']\nport = os.getenv('PORT')",
+		"go.mod":           "module x\n\ngo 1.22\n\ntoolchain go1.22.4\n",
+	}, SourcePreview)
+
+	want := []Language{
+		{Name: "python", Version: dockerize.DefaultPythonVersion, Source: SourcePlatformDefault},
+		{Name: "go", Version: "1.22.4", Source: "go.mod"},
+	}
+	if !reflect.DeepEqual(lock.Languages, want) {
+		t.Fatalf("languages = %+v", lock.Languages)
+	}
+	if len(lock.Services) != 1 || lock.Services[0].Name != "postgres" {
+		t.Fatalf("services = %+v", lock.Services)
+	}
+	if !reflect.DeepEqual(lock.EnvVars, []string{"API_KEY", "PORT"}) {
+		t.Fatalf("env vars = %v", lock.EnvVars)
+	}
+}
+
+func TestCompareReportsDriftAndIgnoresMetadata(t *testing.T) {
+	locked := &Lock{
+		Version:        LockfileVersion,
+		GeneratedBy:    SourceBuild,
+		Languages:      []Language{{Name: "node", Version: "20"}},
+		SystemPackages: []SystemPackage{{Manager: "apt", Name: "curl"}},
+		EnvVars:        []string{"DATABASE_URL", "OLD_KEY"},
+	}
+	current := &Lock{
+		Version:        LockfileVersion,
+		GeneratedBy:    SourcePreview,
+		Languages:      []Language{{Name: "node", Version: "22", Source: SourcePlatformDefault}},
+		SystemPackages: []SystemPackage{{Manager: "apt", Name: "curl"}},
+		EnvVars:        []string{"DATABASE_URL", "NEW_KEY"},
+		Services:       []ServiceDependency{{Name: "redis", Source: "package.json"}},
+	}
+
+	drift := Compare(locked, current)
+	want := []Drift{
+		{Kind: DriftLanguage, Name: "node", Change: ChangeChanged, Locked: "20", Current: "22"},
+		{Kind: DriftEnvVar, Name: "NEW_KEY", Change: ChangeAdded},
+		{Kind: DriftEnvVar, Name: "OLD_KEY", Change: ChangeRemoved},
+		{Kind: DriftService, Name: "redis", Change: ChangeAdded},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Fatalf("drift = %+v", drift)
+	}
+	if summary := Summary(drift); !strings.Contains(summary, "node changed from 20 to 22 and 3 more") {
+		t.Fatalf("summary = %q", summary)
+	}
+	if len(Compare(locked, locked)) != 0 {
+		t.Fatal("a lock should not drift from itself")
+	}
+}
+
+func TestParseRejectsUnusableLocks(t *testing.T) {
+	for _, content := range []string{"not json", `{"lockfile_version": 99}`, `{}`} {
+		if _, err := Parse(content); !errors.Is(err, ErrInvalidLock) {
+			t.Fatalf("Parse(%q) err = %v", content, err)
+		}
+	}
+	encoded, err := Capture(map[string]string{"go.mod": "module x\ngo 1.23\n"}, SourceBuild).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock, err := Parse(encoded); err != nil || lock.Languages[0].Version != "1.23" {
+		t.Fatalf("round trip = %+v, err = %v", lock, err)
+	}
+}
+
+func TestServiceVerifyCreatesThenDetectsDrift(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&models.File{ProjectID: 1, Path: "package.json", Name: "package.json", Type: "file", Content: `{"engines":{"node":"20"}}`})
+	service := NewService(db)
+
+	check, err := service.Verify(1, 7, SourcePreview)
+	if err != nil || check.Status != StatusCreated || check.Lock.GeneratedBy != SourcePreview {
+		t.Fatalf("first check = %+v, err = %v", check, err)
+	}
+	var stored models.File
+	if err := db.Where("project_id = ? AND path = ?", 1, FileName).First(&stored).Error; err != nil || stored.LastEditBy != 7 {
+		t.Fatalf("stored lock = %+v, err = %v", stored, err)
+	}
+
+	if check, err = service.Verify(1, 7, SourceExecution); err != nil || check.Status != StatusOK {
+		t.Fatalf("unchanged check = %+v, err = %v", check, err)
+	}
+
+	db.Model(&models.File{}).Where("project_id = ? AND path = ?", 1, "package.json").Update("content", `{"engines":{"node":"22"}}`)
+	check, err = service.Verify(1, 7, SourceExecution)
+	if err != nil || check.Status != StatusDrift || len(check.Drift) != 1 || check.Warning == "" {
+		t.Fatalf("drift check = %+v, err = %v", check, err)
+	}
+
+	if _, err := service.Regenerate(1, 7); err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+	if check, err = service.Verify(1, 7, SourceExecution); err != nil || check.Status != StatusOK {
+		t.Fatalf("check after regenerate = %+v, err = %v", check, err)
+	}
+}
@@ -0,0 +1,142 @@
+package envlock
+
+import (
+	"errors"
+	"fmt"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Check statuses.
+const (
+	StatusOK      = "ok"
+	StatusDrift   = "drift"
+	StatusCreated = "created"
+	StatusInvalid = "invalid"
+)
+
+// Check is the result of verifying a project against its apex.lock.
+type Check struct {
+	Status string  `json:"status"`
+	Lock   *Lock   `json:"lock,omitempty"`
+	Drift  []Drift `json:"drift,omitempty"`
+	// Warning is a one-line summary for drift or an unreadable lock.
+	Warning string `json:"warning,omitempty"`
+}
+
+// Service verifies projects against their stored apex.lock and writes a
+// lock for projects that don't have one yet.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates an environment lock service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+func (s *Service) loadFiles(projectID uint) (map[string]string, error) {
+	var files []models.File
+	err := s.db.Select("path", "content").
+		Where("project_id = ? AND type <> ? AND is_binary = ?", projectID, "directory", false).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(files))
+	for _, f := range files {
+		out[f.Path] = f.Content
+	}
+	return out, nil
+}
+
+// Verify compares the project's files against its apex.lock. A project
+// without a lock gets one captured from its current state.
+func (s *Service) Verify(projectID, userID uint, source string) (*Check, error) {
+	files, err := s.loadFiles(projectID)
+	if err != nil {
+		return nil, err
+	}
+	current := Capture(files, source)
+
+	content, ok := Find(files)
+	if !ok {
+		if err := s.save(projectID, userID, current); err != nil {
+			return nil, err
+		}
+		return &Check{Status: StatusCreated, Lock: current}, nil
+	}
+	locked, err := Parse(content)
+	if err != nil {
+		return &Check{Status: StatusInvalid, Warning: err.Error()}, nil
+	}
+	drift := Compare(locked, current)
+	if len(drift) == 0 {
+		return &Check{Status: StatusOK, Lock: locked}, nil
+	}
+	return &Check{Status: StatusDrift, Lock: locked, Drift: drift, Warning: Summary(drift)}, nil
+}
+
+// Regenerate replaces the project's apex.lock with its current state.
+func (s *Service) Regenerate(projectID, userID uint) (*Lock, error) {
+	files, err := s.loadFiles(projectID)
+	if err != nil {
+		return nil, err
+	}
+	lock := Capture(files, SourceExecution)
+	if err := s.save(projectID, userID, lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Summary describes drift in one line.
+func Summary(drift []Drift) string {
+	if len(drift) == 0 {
+		return ""
+	}
+	first := drift[0]
+	detail := fmt.Sprintf("%s %s %s", first.Kind, first.Name, first.Change)
+	if first.Change == ChangeChanged {
+		detail = fmt.Sprintf("%s %s changed from %s to %s", first.Kind, first.Name, first.Locked, first.Current)
+	}
+	if len(drift) == 1 {
+		return fmt.Sprintf("environment differs from %s: %s", FileName, detail)
+	}
+	return fmt.Sprintf("environment differs from %s: %s and %d more", FileName, detail, len(drift)-1)
+}
+
+func (s *Service) save(projectID, userID uint, lock *Lock) error {
+	content, err := lock.Encode()
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var file models.File
+		err := tx.Where("project_id = ? AND path IN ?", projectID, lockFilePaths).First(&file).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			file = models.File{
+				ProjectID:  projectID,
+				Path:       FileName,
+				Name:       FileName,
+				Type:       "file",
+				MimeType:   "application/json",
+				Content:    content,
+				Size:       int64(len(content)),
+				LastEditBy: userID,
+			}
+			return tx.Create(&file).Error
+		case err != nil:
+			return err
+		}
+		return tx.Model(&file).Updates(map[string]interface{}{
+			"content":      content,
+			"size":         int64(len(content)),
+			"version":      file.Version + 1,
+			"last_edit_by": userID,
+		}).Error
+	})
+}
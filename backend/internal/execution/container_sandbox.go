@@ -114,7 +114,7 @@ type ContainerSandboxConfig struct {
 	MaxConcurrentExecs int32
 }
 
-var sandboxImageLanguages = []string{"python", "javascript", "go", "rust", "java", "c", "cpp"}
+var sandboxImageLanguages = []string{"python", "javascript", "go", "rust", "java", "c", "cpp", "kotlin", "swift", "csharp", "elixir"}
 
 // LanguageResourceLimits defines per-language resource constraints
 type LanguageResourceLimits struct {
@@ -283,6 +283,34 @@ func DefaultContainerSandboxConfig() *ContainerSandboxConfig {
 				PidsLimit:   50,
 				TmpfsSize:   "64m",
 			},
+			"kotlin": {
+				MemoryLimit: 768 * 1024 * 1024,
+				CPULimit:    1.0,
+				Timeout:     90 * time.Second,
+				PidsLimit:   200,
+				TmpfsSize:   "256m",
+			},
+			"swift": {
+				MemoryLimit: 512 * 1024 * 1024,
+				CPULimit:    1.0,
+				Timeout:     60 * time.Second,
+				PidsLimit:   100,
+				TmpfsSize:   "256m",
+			},
+			"csharp": {
+				MemoryLimit: 768 * 1024 * 1024,
+				CPULimit:    1.0,
+				Timeout:     90 * time.Second,
+				PidsLimit:   200,
+				TmpfsSize:   "256m",
+			},
+			"elixir": {
+				MemoryLimit: 256 * 1024 * 1024,
+				CPULimit:    0.5,
+				Timeout:     30 * time.Second,
+				PidsLimit:   100,
+				TmpfsSize:   "64m",
+			},
 		},
 	}
 }
@@ -744,6 +772,54 @@ RUN apt-get update && apt-get install -y --no-install-recommends \
     chown -R sandbox:sandbox /work /tmp
 USER sandbox
 WORKDIR /work
+`, aptPackages, postInstall)
+	case "kotlin":
+		return fmt.Sprintf(`FROM eclipse-temurin:21-jdk-jammy
+RUN apt-get update && apt-get install -y --no-install-recommends \
+    %s%s && \
+    rm -rf /var/lib/apt/lists/* && \
+    useradd -m -s /bin/bash sandbox && \
+    mkdir -p /work /tmp && \
+    chown -R sandbox:sandbox /work /tmp
+USER sandbox
+WORKDIR /work
+ENV GRADLE_USER_HOME=/tmp/.gradle
+`, aptPackages, postInstall)
+	case "swift":
+		return fmt.Sprintf(`FROM swift:5.10-jammy
+RUN apt-get update && apt-get install -y --no-install-recommends \
+    %s%s && \
+    rm -rf /var/lib/apt/lists/* && \
+    useradd -m -s /bin/bash sandbox && \
+    mkdir -p /work /tmp && \
+    chown -R sandbox:sandbox /work /tmp
+USER sandbox
+WORKDIR /work
+ENV HOME=/tmp
+`, aptPackages, postInstall)
+	case "csharp":
+		return fmt.Sprintf(`FROM mcr.microsoft.com/dotnet/sdk:8.0-jammy
+RUN apt-get update && apt-get install -y --no-install-recommends \
+    %s%s && \
+    rm -rf /var/lib/apt/lists/* && \
+    useradd -m -s /bin/bash sandbox && \
+    mkdir -p /work /tmp && \
+    chown -R sandbox:sandbox /work /tmp
+USER sandbox
+WORKDIR /work
+ENV DOTNET_CLI_HOME=/tmp DOTNET_NOLOGO=1 DOTNET_CLI_TELEMETRY_OPTOUT=1 NUGET_PACKAGES=/tmp/.nuget
+`, aptPackages, postInstall)
+	case "elixir":
+		return fmt.Sprintf(`FROM elixir:1.17-slim
+RUN apt-get update && apt-get install -y --no-install-recommends \
+    %s%s && \
+    rm -rf /var/lib/apt/lists/* && \
+    useradd -m -s /bin/bash sandbox && \
+    mkdir -p /work /tmp && \
+    chown -R sandbox:sandbox /work /tmp
+USER sandbox
+WORKDIR /work
+ENV MIX_HOME=/tmp/.mix HEX_HOME=/tmp/.hex
 `, aptPackages, postInstall)
 	default:
 		return fmt.Sprintf(`FROM debian:bookworm-slim
@@ -1074,6 +1150,27 @@ func (s *ContainerSandbox) writeCodeFile(tempDir, language, code string) (string
 		} else {
 			processedCode = code
 		}
+	case "kotlin":
+		filename = "main.kt"
+		// Ensure main function
+		if !strings.Contains(code, "fun main") {
+			processedCode = "fun main() {\n" + code + "\n}"
+		} else {
+			processedCode = code
+		}
+	case "swift":
+		// main.swift allows top-level statements
+		filename = "main.swift"
+		processedCode = code
+	case "csharp":
+		filename = "Program.cs"
+		processedCode = code
+		if err := os.WriteFile(filepath.Join(tempDir, "app.csproj"), []byte(dotnetConsoleProject), 0644); err != nil {
+			return "", err
+		}
+	case "elixir":
+		filename = "main.exs"
+		processedCode = code
 	default:
 		return "", fmt.Errorf("unsupported language: %s", language)
 	}
@@ -1572,6 +1669,16 @@ func (s *ContainerSandbox) getImageName(language string) string {
 		return "eclipse-temurin:21-jdk"
 	case "c", "cpp":
 		return "gcc:13"
+	case "kotlin":
+		// There is no official kotlinc image; the JDK image runs compiled jars
+		// and the enhanced sandbox image supplies kotlinc.
+		return "eclipse-temurin:21-jdk"
+	case "swift":
+		return "swift:5.10"
+	case "csharp":
+		return "mcr.microsoft.com/dotnet/sdk:8.0"
+	case "elixir":
+		return "elixir:1.17-slim"
 	default:
 		return "debian:bookworm-slim"
 	}
@@ -1596,6 +1703,15 @@ func (s *ContainerSandbox) getExecutionCommand(language, filename string) []stri
 		return []string{"sh", "-c", fmt.Sprintf("gcc -o /tmp/main %s -lm && /tmp/main", filename)}
 	case "cpp":
 		return []string{"sh", "-c", fmt.Sprintf("g++ -o /tmp/main -std=c++17 %s && /tmp/main", filename)}
+	case "kotlin":
+		return []string{"sh", "-c", fmt.Sprintf("command -v kotlinc >/dev/null || { echo 'kotlinc is not installed in this sandbox image' >&2; exit 127; }; kotlinc %s -nowarn -include-runtime -d /tmp/main.jar && java -jar /tmp/main.jar", filename)}
+	case "swift":
+		return []string{"sh", "-c", fmt.Sprintf("swiftc -module-cache-path /tmp/swift-cache -o /tmp/main %s && /tmp/main", filename)}
+	case "csharp":
+		// dotnet writes obj/ and bin/ next to the project, so build from a /tmp copy.
+		return []string{"sh", "-c", fmt.Sprintf("export DOTNET_CLI_HOME=/tmp DOTNET_NOLOGO=1 DOTNET_CLI_TELEMETRY_OPTOUT=1 NUGET_PACKAGES=/tmp/.nuget && mkdir -p /tmp/app && cp %s app.csproj /tmp/app/ && dotnet run --project /tmp/app", filename)}
+	case "elixir":
+		return []string{"sh", "-c", fmt.Sprintf("HOME=/tmp elixir %s", filename)}
 	default:
 		return []string{"sh", "-c", "echo 'Unsupported language'"}
 	}
@@ -1604,7 +1720,7 @@ func (s *ContainerSandbox) getExecutionCommand(language, filename string) []stri
 // languageNeedsExecutableTmp returns true when /tmp must allow executing compiled artifacts.
 func (s *ContainerSandbox) languageNeedsExecutableTmp(language string) bool {
 	switch strings.ToLower(strings.TrimSpace(language)) {
-	case "go", "rust", "c", "cpp", "java":
+	case "go", "rust", "c", "cpp", "java", "kotlin", "swift", "csharp":
 		return true
 	default:
 		return false
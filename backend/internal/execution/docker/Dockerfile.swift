@@ -0,0 +1,32 @@
+# APEX.BUILD Swift Sandbox Image
+# Minimal, secure Swift execution environment
+
+FROM swift:5.10-jammy
+
+# Security: Create unprivileged user
+RUN groupadd -r sandbox && useradd -r -g sandbox -d /home/sandbox -s /bin/false sandbox
+
+# Install minimal dependencies
+RUN apt-get update && apt-get install -y --no-install-recommends \
+    ca-certificates \
+    && rm -rf /var/lib/apt/lists/* \
+    && rm -rf /var/cache/apt/archives/*
+
+# Create work directories
+RUN mkdir -p /work /tmp/sandbox \
+    && chown -R sandbox:sandbox /work /tmp/sandbox \
+    && chmod 1777 /tmp/sandbox
+
+# Remove unnecessary binaries
+RUN rm -f /usr/bin/curl /usr/bin/wget /usr/bin/nc /usr/bin/netcat \
+    /usr/bin/ssh /usr/bin/scp /usr/bin/sftp 2>/dev/null || true
+
+# The compiler writes its module cache under HOME
+ENV HOME=/tmp
+
+# Switch to unprivileged user
+USER sandbox
+WORKDIR /work
+
+# Default command
+CMD ["swift", "--version"]
@@ -12,7 +12,7 @@ echo "Image prefix: $IMAGE_PREFIX"
 echo ""
 
 # Languages to build
-LANGUAGES=("python" "javascript" "go" "rust" "java" "c")
+LANGUAGES=("python" "javascript" "go" "rust" "java" "c" "kotlin" "swift" "csharp" "elixir")
 
 # Build each image
 for lang in "${LANGUAGES[@]}"; do
@@ -378,6 +378,12 @@ func (e *E2BSandbox) getLanguageCommands(language, code string) (string, string,
 		"c++":       "cpp",
 		"cplusplus": "cpp",
 		"rb":        "ruby",
+		"kt":        "kotlin",
+		"cs":        "csharp",
+		"c#":        "csharp",
+		"dotnet":    "csharp",
+		"ex":        "elixir",
+		"exs":       "elixir",
 	}
 
 	if alias, ok := aliases[language]; ok {
@@ -405,6 +411,14 @@ func (e *E2BSandbox) getLanguageCommands(language, code string) (string, string,
 		return "/code/main.rb", "", "cd /code && ruby main.rb", nil
 	case "php":
 		return "/code/main.php", "", "cd /code && php main.php", nil
+	case "kotlin":
+		return "/code/main.kt", "", "cd /code && kotlinc main.kt -nowarn -include-runtime -d main.jar && java -jar main.jar", nil
+	case "swift":
+		return "/code/main.swift", "", "cd /code && swiftc -o main main.swift && ./main", nil
+	case "csharp":
+		return "/code/Program.cs", "cd /code && dotnet new console --force -o . >/dev/null", "cd /code && dotnet run", nil
+	case "elixir":
+		return "/code/main.exs", "", "cd /code && elixir main.exs", nil
 	default:
 		return "", "", "", fmt.Errorf("unsupported language: %s", language)
 	}
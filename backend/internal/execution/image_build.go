@@ -0,0 +1,69 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+)
+
+// projectImageBuildTimeout bounds a project image build; dependency installs
+// make these much slower than the sandbox's own language images
+const projectImageBuildTimeout = 10 * time.Minute
+
+// BuildImage builds the Dockerfile at the root of contextDir and tags the
+// result. It returns the build output either way, so callers can show why a
+// build failed.
+func (s *ContainerSandbox) BuildImage(ctx context.Context, contextDir, tag string) (string, error) {
+	if !s.checkDockerAvailable() {
+		return "", fmt.Errorf("docker is not available")
+	}
+	ctx, cancel := context.WithTimeout(ctx, projectImageBuildTimeout)
+	defer cancel()
+	// BuildKit reports progress on stderr, so keep both streams in order
+	var output bytes.Buffer
+	cmd := s.dockerCommandContext(ctx, "build", "--progress=plain", "--label", "apex.verify=true", "-t", tag, contextDir)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			killCommandProcessGroup(cmd)
+			err = fmt.Errorf("timed out after %s: %w", projectImageBuildTimeout, ctx.Err())
+		}
+		return output.String(), fmt.Errorf("docker build failed: %w", err)
+	}
+	return output.String(), nil
+}
+
+// RemoveImage deletes a tagged image, ignoring images that are already gone
+func (s *ContainerSandbox) RemoveImage(ctx context.Context, tag string) error {
+	ctx, cancel := context.WithTimeout(ctx, dockerMetadataTimeout*6)
+	defer cancel()
+	cmd := s.dockerCommandContext(ctx, "image", "rm", "--force", tag)
+	if output, err := runCommandWithSoftDeadline(cmd, dockerMetadataTimeout*6); err != nil {
+		return fmt.Errorf("docker image rm failed: %s", string(output))
+	}
+	return nil
+}
+
+// BuildImage builds a project image in the container sandbox's Docker daemon
+func (f *SandboxFactory) BuildImage(ctx context.Context, contextDir, tag string) (string, error) {
+	f.mu.RLock()
+	containerSandbox := f.containerSandbox
+	f.mu.RUnlock()
+	if containerSandbox == nil {
+		return "", fmt.Errorf("image builds require the container sandbox")
+	}
+	return containerSandbox.BuildImage(ctx, contextDir, tag)
+}
+
+// RemoveImage deletes an image built with BuildImage
+func (f *SandboxFactory) RemoveImage(ctx context.Context, tag string) error {
+	f.mu.RLock()
+	containerSandbox := f.containerSandbox
+	f.mu.RUnlock()
+	if containerSandbox == nil {
+		return fmt.Errorf("image builds require the container sandbox")
+	}
+	return containerSandbox.RemoveImage(ctx, tag)
+}
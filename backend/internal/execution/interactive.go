@@ -0,0 +1,137 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"io"
+	osexec "os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InteractiveProcess is a long-lived sandboxed process driven over stdin and
+// stdout, such as a notebook kernel. Unlike Execute it has no per-run
+// timeout; the caller owns its lifetime and must call Kill when done.
+type InteractiveProcess struct {
+	ID       string
+	Language string
+	Stdin    io.WriteCloser
+	Stdout   io.Reader
+	Stderr   io.Reader
+
+	sandbox     *ContainerSandbox
+	containerID string
+	cmd         *osexec.Cmd
+	cancel      context.CancelFunc
+	waitOnce    sync.Once
+	waitErr     error
+}
+
+// Interrupt delivers SIGINT to the process inside the container so the
+// running statement raises KeyboardInterrupt without losing interpreter state.
+func (p *InteractiveProcess) Interrupt() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerMetadataTimeout)
+	defer cancel()
+	output, err := p.sandbox.dockerCommandContext(ctx, "kill", "--signal=INT", p.containerID).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("interrupt %s: %s", p.ID, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Wait blocks until the process exits.
+func (p *InteractiveProcess) Wait() error {
+	p.waitOnce.Do(func() {
+		p.waitErr = p.cmd.Wait()
+		p.cancel()
+		p.sandbox.executionsMu.Lock()
+		if exec, ok := p.sandbox.executions[p.ID]; ok {
+			close(exec.Done)
+			delete(p.sandbox.executions, p.ID)
+		}
+		p.sandbox.executionsMu.Unlock()
+	})
+	return p.waitErr
+}
+
+// Kill stops the process and removes its container.
+func (p *InteractiveProcess) Kill() error {
+	p.Stdin.Close()
+	p.cancel()
+	p.sandbox.forceKillContainer(p.containerID)
+	return p.Wait()
+}
+
+// StartInteractive launches command in a fresh container with stdin attached
+// and the language's resource and security limits. Nothing is mounted; the
+// process works in its tmpfs /tmp.
+func (s *ContainerSandbox) StartInteractive(ctx context.Context, language string, command []string, env map[string]string) (*InteractiveProcess, error) {
+	execID := generateExecutionID()
+	limits := s.getResourceLimits(language)
+	runCtx, cancel := context.WithCancel(ctx)
+
+	exec := &containerExecution{
+		ID:        execID,
+		Language:  language,
+		StartTime: time.Now(),
+		Cancel:    cancel,
+		Done:      make(chan struct{}),
+	}
+	args := s.buildDockerArgs(exec, limits, s.getImageName(language), containerRunOptions{
+		WorkDir: "/tmp",
+		Command: command,
+		Env:     env,
+	})
+	args = append(args[:1], append([]string{"-i"}, args[1:]...)...)
+
+	cmd := s.dockerCommandContext(runCtx, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start interactive container: %w", err)
+	}
+
+	s.executionsMu.Lock()
+	s.executions[execID] = exec
+	s.executionsMu.Unlock()
+
+	return &InteractiveProcess{
+		ID:          execID,
+		Language:    language,
+		Stdin:       stdin,
+		Stdout:      stdout,
+		Stderr:      stderr,
+		sandbox:     s,
+		containerID: exec.ContainerID,
+		cmd:         cmd,
+		cancel:      cancel,
+	}, nil
+}
+
+// StartInteractive launches a long-lived process in the container sandbox.
+// Interactive sessions keep interpreter state between requests, so they are
+// never run in the process sandbox.
+func (f *SandboxFactory) StartInteractive(ctx context.Context, language string, command []string, env map[string]string) (*InteractiveProcess, error) {
+	f.mu.RLock()
+	containerSandbox := f.containerSandbox
+	f.mu.RUnlock()
+	if containerSandbox == nil {
+		return nil, fmt.Errorf("interactive sessions require the container sandbox")
+	}
+	return containerSandbox.StartInteractive(ctx, language, command, env)
+}
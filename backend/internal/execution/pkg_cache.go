@@ -70,6 +70,18 @@ func (m *PackageCacheManager) MountsForLanguage(language string) []PackageCacheM
 		return []PackageCacheMount{
 			m.mount("m2", "/cache/m2", map[string]string{"MAVEN_CONFIG": "/cache/m2"}),
 		}
+	case "kotlin", "kt":
+		return []PackageCacheMount{
+			m.mount("gradle", "/cache/gradle", map[string]string{"GRADLE_USER_HOME": "/cache/gradle"}),
+		}
+	case "csharp", "cs", "dotnet":
+		return []PackageCacheMount{
+			m.mount("nuget", "/cache/nuget", map[string]string{"NUGET_PACKAGES": "/cache/nuget"}),
+		}
+	case "elixir", "ex":
+		return []PackageCacheMount{
+			m.mount("hex", "/cache/hex", map[string]string{"HEX_HOME": "/cache/hex"}),
+		}
 	default:
 		return nil
 	}
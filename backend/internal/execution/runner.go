@@ -59,17 +59,25 @@ func GetRunner(language string) (Runner, error) {
 
 	// Check aliases
 	aliases := map[string]string{
-		"js":         "javascript",
-		"node":       "javascript",
-		"nodejs":     "javascript",
-		"ts":         "typescript",
-		"py":         "python",
-		"python3":    "python",
-		"golang":     "go",
-		"rs":         "rust",
-		"c++":        "cpp",
-		"cplusplus":  "cpp",
-		"rb":         "ruby",
+		"js":        "javascript",
+		"node":      "javascript",
+		"nodejs":    "javascript",
+		"ts":        "typescript",
+		"py":        "python",
+		"python3":   "python",
+		"golang":    "go",
+		"rs":        "rust",
+		"c++":       "cpp",
+		"cplusplus": "cpp",
+		"rb":        "ruby",
+		"kt":        "kotlin",
+		"kts":       "kotlin",
+		"cs":        "csharp",
+		"c#":        "csharp",
+		"dotnet":    "csharp",
+		".net":      "csharp",
+		"ex":        "elixir",
+		"exs":       "elixir",
 	}
 
 	if alias, ok := aliases[language]; ok {
@@ -93,6 +101,10 @@ func init() {
 	RegisterRunner(&JavaRunner{})
 	RegisterRunner(&RubyRunner{})
 	RegisterRunner(&PHPRunner{})
+	RegisterRunner(&KotlinRunner{})
+	RegisterRunner(&SwiftRunner{})
+	RegisterRunner(&CSharpRunner{})
+	RegisterRunner(&ElixirRunner{})
 }
 
 // =============================================================================
@@ -833,3 +845,331 @@ func (r *PHPRunner) NeedsCompilation() bool {
 func (r *PHPRunner) Compile(tempDir, filename string) (string, error) {
 	return filepath.Join(tempDir, filename), nil
 }
+
+// =============================================================================
+// KotlinRunner - Kotlin/JVM execution with kotlinc
+// =============================================================================
+
+type KotlinRunner struct{}
+
+func (r *KotlinRunner) Language() string {
+	return "kotlin"
+}
+
+func (r *KotlinRunner) Extensions() []string {
+	return []string{".kt"}
+}
+
+func (r *KotlinRunner) WriteCode(tempDir, code string) (string, error) {
+	filename := "main.kt"
+	filePath := filepath.Join(tempDir, filename)
+
+	// Ensure code has main function wrapper if it doesn't
+	if !strings.Contains(code, "fun main") {
+		code = "fun main() {\n" + code + "\n}"
+	}
+
+	if err := os.WriteFile(filePath, []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write Kotlin file: %w", err)
+	}
+	return filename, nil
+}
+
+func (r *KotlinRunner) BuildCommand(tempDir, filename string) (*exec.Cmd, error) {
+	jarPath, err := r.Compile(tempDir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	javaPath, err := exec.LookPath("java")
+	if err != nil {
+		return nil, fmt.Errorf("java not found")
+	}
+
+	cmd := exec.Command(javaPath, "-jar", jarPath)
+	return cmd, nil
+}
+
+func (r *KotlinRunner) BuildCommandForFile(filePath, tempDir string, args []string) (*exec.Cmd, error) {
+	kotlincPath, err := exec.LookPath("kotlinc")
+	if err != nil {
+		return nil, fmt.Errorf("kotlinc not found")
+	}
+
+	javaPath, err := exec.LookPath("java")
+	if err != nil {
+		return nil, fmt.Errorf("java not found")
+	}
+
+	jarPath := filepath.Join(tempDir, "main.jar")
+
+	// Compile
+	compileCmd := exec.Command(kotlincPath, filePath, "-include-runtime", "-d", jarPath)
+	output, err := compileCmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("compilation failed: %s", string(output))
+	}
+
+	cmdArgs := append([]string{"-jar", jarPath}, args...)
+	cmd := exec.Command(javaPath, cmdArgs...)
+	return cmd, nil
+}
+
+func (r *KotlinRunner) NeedsCompilation() bool {
+	return true
+}
+
+func (r *KotlinRunner) Compile(tempDir, filename string) (string, error) {
+	kotlincPath, err := exec.LookPath("kotlinc")
+	if err != nil {
+		return "", fmt.Errorf("kotlinc not found")
+	}
+
+	srcPath := filepath.Join(tempDir, filename)
+	jarPath := filepath.Join(tempDir, "main.jar")
+
+	cmd := exec.Command(kotlincPath, srcPath, "-include-runtime", "-d", jarPath)
+	cmd.Dir = tempDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("compilation failed: %s", string(output))
+	}
+
+	return jarPath, nil
+}
+
+// =============================================================================
+// SwiftRunner - Swift execution with swiftc
+// =============================================================================
+
+type SwiftRunner struct{}
+
+func (r *SwiftRunner) Language() string {
+	return "swift"
+}
+
+func (r *SwiftRunner) Extensions() []string {
+	return []string{".swift"}
+}
+
+func (r *SwiftRunner) WriteCode(tempDir, code string) (string, error) {
+	// main.swift allows top-level statements, so no wrapper is needed.
+	filename := "main.swift"
+	filePath := filepath.Join(tempDir, filename)
+
+	if err := os.WriteFile(filePath, []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write Swift file: %w", err)
+	}
+	return filename, nil
+}
+
+func (r *SwiftRunner) BuildCommand(tempDir, filename string) (*exec.Cmd, error) {
+	binPath, err := r.Compile(tempDir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(binPath)
+	return cmd, nil
+}
+
+func (r *SwiftRunner) BuildCommandForFile(filePath, tempDir string, args []string) (*exec.Cmd, error) {
+	swiftcPath, err := exec.LookPath("swiftc")
+	if err != nil {
+		return nil, fmt.Errorf("swiftc not found")
+	}
+
+	binPath := filepath.Join(tempDir, "main")
+
+	// Compile
+	compileCmd := exec.Command(swiftcPath, "-o", binPath, filePath)
+	output, err := compileCmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("compilation failed: %s", string(output))
+	}
+
+	cmd := exec.Command(binPath, args...)
+	return cmd, nil
+}
+
+func (r *SwiftRunner) NeedsCompilation() bool {
+	return true
+}
+
+func (r *SwiftRunner) Compile(tempDir, filename string) (string, error) {
+	swiftcPath, err := exec.LookPath("swiftc")
+	if err != nil {
+		return "", fmt.Errorf("swiftc not found")
+	}
+
+	srcPath := filepath.Join(tempDir, filename)
+	binPath := filepath.Join(tempDir, "main")
+
+	cmd := exec.Command(swiftcPath, "-o", binPath, srcPath)
+	cmd.Dir = tempDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("compilation failed: %s", string(output))
+	}
+
+	return binPath, nil
+}
+
+// =============================================================================
+// CSharpRunner - C#/.NET execution with the dotnet SDK
+// =============================================================================
+
+// dotnetConsoleProject is the project file written next to single-file C#
+// code so the dotnet SDK can build it. Top-level statements and implicit
+// usings let snippets run without a Program class.
+const dotnetConsoleProject = `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <OutputType>Exe</OutputType>
+    <TargetFramework>net8.0</TargetFramework>
+    <ImplicitUsings>enable</ImplicitUsings>
+    <Nullable>enable</Nullable>
+    <AssemblyName>app</AssemblyName>
+  </PropertyGroup>
+</Project>
+`
+
+type CSharpRunner struct{}
+
+func (r *CSharpRunner) Language() string {
+	return "csharp"
+}
+
+func (r *CSharpRunner) Extensions() []string {
+	return []string{".cs"}
+}
+
+func (r *CSharpRunner) WriteCode(tempDir, code string) (string, error) {
+	filename := "Program.cs"
+	filePath := filepath.Join(tempDir, filename)
+
+	if err := os.WriteFile(filePath, []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write C# file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "app.csproj"), []byte(dotnetConsoleProject), 0644); err != nil {
+		return "", fmt.Errorf("failed to write C# project file: %w", err)
+	}
+	return filename, nil
+}
+
+func (r *CSharpRunner) BuildCommand(tempDir, filename string) (*exec.Cmd, error) {
+	dllPath, err := r.Compile(tempDir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	dotnetPath, err := exec.LookPath("dotnet")
+	if err != nil {
+		return nil, fmt.Errorf("dotnet not found")
+	}
+
+	cmd := exec.Command(dotnetPath, dllPath)
+	return cmd, nil
+}
+
+func (r *CSharpRunner) BuildCommandForFile(filePath, tempDir string, args []string) (*exec.Cmd, error) {
+	dotnetPath, err := exec.LookPath("dotnet")
+	if err != nil {
+		return nil, fmt.Errorf("dotnet not found")
+	}
+
+	// Use the file's own project when it has one; otherwise build it as a
+	// single-file console app in tempDir.
+	projectDir := filepath.Dir(filePath)
+	if projects, _ := filepath.Glob(filepath.Join(projectDir, "*.csproj")); len(projects) == 0 {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if _, err := r.WriteCode(tempDir, string(content)); err != nil {
+			return nil, err
+		}
+		projectDir = tempDir
+	}
+
+	cmdArgs := append([]string{"run", "--project", projectDir, "--"}, args...)
+	cmd := exec.Command(dotnetPath, cmdArgs...)
+	return cmd, nil
+}
+
+func (r *CSharpRunner) NeedsCompilation() bool {
+	return true
+}
+
+func (r *CSharpRunner) Compile(tempDir, filename string) (string, error) {
+	dotnetPath, err := exec.LookPath("dotnet")
+	if err != nil {
+		return "", fmt.Errorf("dotnet not found")
+	}
+
+	outDir := filepath.Join(tempDir, "out")
+
+	cmd := exec.Command(dotnetPath, "build", "--nologo", "-c", "Release", "-o", outDir)
+	cmd.Dir = tempDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("compilation failed: %s", string(output))
+	}
+
+	return filepath.Join(outDir, "app.dll"), nil
+}
+
+// =============================================================================
+// ElixirRunner - Elixir script execution
+// =============================================================================
+
+type ElixirRunner struct{}
+
+func (r *ElixirRunner) Language() string {
+	return "elixir"
+}
+
+func (r *ElixirRunner) Extensions() []string {
+	return []string{".ex", ".exs"}
+}
+
+func (r *ElixirRunner) WriteCode(tempDir, code string) (string, error) {
+	filename := "main.exs"
+	filePath := filepath.Join(tempDir, filename)
+
+	if err := os.WriteFile(filePath, []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write Elixir file: %w", err)
+	}
+	return filename, nil
+}
+
+func (r *ElixirRunner) BuildCommand(tempDir, filename string) (*exec.Cmd, error) {
+	elixirPath, err := exec.LookPath("elixir")
+	if err != nil {
+		return nil, fmt.Errorf("elixir not found")
+	}
+
+	cmd := exec.Command(elixirPath, filepath.Join(tempDir, filename))
+	return cmd, nil
+}
+
+func (r *ElixirRunner) BuildCommandForFile(filePath, tempDir string, args []string) (*exec.Cmd, error) {
+	elixirPath, err := exec.LookPath("elixir")
+	if err != nil {
+		return nil, fmt.Errorf("elixir not found")
+	}
+
+	cmdArgs := append([]string{filePath}, args...)
+	cmd := exec.Command(elixirPath, cmdArgs...)
+	return cmd, nil
+}
+
+func (r *ElixirRunner) NeedsCompilation() bool {
+	return false
+}
+
+func (r *ElixirRunner) Compile(tempDir, filename string) (string, error) {
+	return filepath.Join(tempDir, filename), nil
+}
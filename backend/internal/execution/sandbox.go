@@ -596,6 +596,14 @@ func detectLanguageFromFile(path string) string {
 		return "ruby"
 	case ".php":
 		return "php"
+	case ".kt":
+		return "kotlin"
+	case ".swift":
+		return "swift"
+	case ".cs":
+		return "csharp"
+	case ".ex", ".exs":
+		return "elixir"
 	default:
 		return ""
 	}
@@ -685,6 +693,34 @@ func GetSupportedLanguages() []SupportedLanguage {
 			Compiled:    false,
 			Description: "PHP interpreter",
 		},
+		{
+			ID:          "kotlin",
+			Name:        "Kotlin",
+			Extensions:  []string{".kt"},
+			Compiled:    true,
+			Description: "Kotlin/JVM with kotlinc",
+		},
+		{
+			ID:          "swift",
+			Name:        "Swift",
+			Extensions:  []string{".swift"},
+			Compiled:    true,
+			Description: "Swift with swiftc",
+		},
+		{
+			ID:          "csharp",
+			Name:        "C#",
+			Extensions:  []string{".cs"},
+			Compiled:    true,
+			Description: "C# with the .NET SDK",
+		},
+		{
+			ID:          "elixir",
+			Name:        "Elixir",
+			Extensions:  []string{".ex", ".exs"},
+			Compiled:    false,
+			Description: "Elixir on the BEAM",
+		},
 	}
 
 	// Check version and availability for each language
@@ -720,6 +756,14 @@ func checkLanguageAvailability(language string) (version string, available bool)
 		cmd = exec.Command("ruby", "--version")
 	case "php":
 		cmd = exec.Command("php", "--version")
+	case "kotlin":
+		cmd = exec.Command("kotlinc", "-version")
+	case "swift":
+		cmd = exec.Command("swift", "--version")
+	case "csharp":
+		cmd = exec.Command("dotnet", "--version")
+	case "elixir":
+		cmd = exec.Command("elixir", "--version")
 	default:
 		return "unknown", false
 	}
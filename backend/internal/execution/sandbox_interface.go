@@ -637,6 +637,7 @@ func (f *SandboxFactory) GetCapabilities() SandboxCapabilities {
 		SupportedLanguages: []string{
 			"python", "javascript", "typescript", "go", "rust",
 			"c", "cpp", "java", "ruby", "php",
+			"kotlin", "swift", "csharp", "elixir",
 		},
 	}
 
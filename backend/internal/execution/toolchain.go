@@ -145,6 +145,43 @@ func fallbackToolchainProfileForLanguage(language string) SandboxToolchainProfil
 			nil,
 			nil,
 		)
+	case "kotlin":
+		// The public JDK fallback image can run jars but has no kotlinc.
+		return newSandboxToolchainProfile(
+			[]string{"java"},
+			nil,
+			nil,
+			[]string{"java"},
+			nil,
+			nil,
+		)
+	case "swift":
+		return newSandboxToolchainProfile(
+			[]string{"swift"},
+			nil,
+			nil,
+			[]string{"swift", "swiftc"},
+			nil,
+			nil,
+		)
+	case "csharp":
+		return newSandboxToolchainProfile(
+			[]string{"dotnet"},
+			nil,
+			nil,
+			[]string{"dotnet"},
+			nil,
+			nil,
+		)
+	case "elixir":
+		return newSandboxToolchainProfile(
+			[]string{"mix"},
+			nil,
+			nil,
+			[]string{"elixir", "mix"},
+			nil,
+			nil,
+		)
 	default:
 		return SandboxToolchainProfile{}
 	}
@@ -224,13 +261,49 @@ func enhancedToolchainProfileForLanguage(language string) SandboxToolchainProfil
 			nil,
 			nil,
 		))
+	case "kotlin":
+		return mergeToolchainProfiles(common, newSandboxToolchainProfile(
+			[]string{"gradle", "java"},
+			nil,
+			nil,
+			[]string{"gradle", "java", "kotlinc"},
+			nil,
+			[]string{"gradle"},
+		))
+	case "swift":
+		return mergeToolchainProfiles(common, newSandboxToolchainProfile(
+			[]string{"swift"},
+			nil,
+			nil,
+			[]string{"swift", "swiftc"},
+			nil,
+			nil,
+		))
+	case "csharp":
+		return mergeToolchainProfiles(common, newSandboxToolchainProfile(
+			[]string{"dotnet"},
+			nil,
+			nil,
+			[]string{"dotnet"},
+			nil,
+			nil,
+		))
+	case "elixir":
+		return mergeToolchainProfiles(common, newSandboxToolchainProfile(
+			[]string{"mix"},
+			nil,
+			nil,
+			[]string{"elixir", "mix"},
+			nil,
+			[]string{"mix"},
+		))
 	default:
 		return common
 	}
 }
 
 func containerLanguageToolchainProfiles(enhancedLanguages map[string]bool) map[string]SandboxToolchainProfile {
-	languages := []string{"python", "javascript", "go", "rust", "java", "c", "cpp"}
+	languages := []string{"python", "javascript", "go", "rust", "java", "c", "cpp", "kotlin", "swift", "csharp", "elixir"}
 	profiles := make(map[string]SandboxToolchainProfile, len(languages))
 	for _, language := range languages {
 		if enhancedLanguages[language] {
@@ -341,6 +414,11 @@ func sandboxGlobalInstallCommands(language string) []string {
 			"npm install -g pnpm typescript tsx vite serve prisma drizzle-kit vercel netlify-cli wrangler @railway/cli",
 			"curl -fsSL https://github.com/supabase/cli/releases/latest/download/supabase_linux_amd64.tar.gz | tar -xz -C /usr/local/bin supabase",
 		}
+	case "kotlin":
+		return []string{
+			"curl -fsSL https://github.com/JetBrains/kotlin/releases/download/v2.0.21/kotlin-compiler-2.0.21.zip -o /tmp/kotlinc.zip && unzip -q /tmp/kotlinc.zip -d /opt && rm /tmp/kotlinc.zip && ln -s /opt/kotlinc/bin/kotlinc /usr/local/bin/kotlinc",
+			"curl -fsSL https://services.gradle.org/distributions/gradle-8.10.2-bin.zip -o /tmp/gradle.zip && unzip -q /tmp/gradle.zip -d /opt && rm /tmp/gradle.zip && ln -s /opt/gradle-8.10.2/bin/gradle /usr/local/bin/gradle",
+		}
 	default:
 		return nil
 	}
@@ -348,10 +426,10 @@ func sandboxGlobalInstallCommands(language string) []string {
 
 func DefaultAgentCommandCatalog() []string {
 	return normalizeCLINames([]string{
-		"cargo", "composer", "curl", "drizzle-kit", "g++", "gcc", "git", "go", "gofmt",
-		"gradle", "java", "javac", "jq", "make", "mvn", "mysql", "nc", "netlify", "node",
+		"cargo", "composer", "curl", "dotnet", "drizzle-kit", "elixir", "g++", "gcc", "git", "go", "gofmt",
+		"gradle", "java", "javac", "jq", "kotlinc", "make", "mix", "mvn", "mysql", "nc", "netlify", "node",
 		"npm", "npx", "pip", "pip3", "pipenv", "pnpm", "poetry", "prisma",
 		"psql", "python", "python3", "railway", "redis-cli", "rg", "rustc", "serve", "supabase",
-		"sqlite3", "tsc", "tsx", "uv", "vercel", "vite", "wget", "wrangler", "yarn",
+		"sqlite3", "swift", "swiftc", "tsc", "tsx", "uv", "vercel", "vite", "wget", "wrangler", "yarn",
 	})
 }
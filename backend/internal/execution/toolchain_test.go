@@ -1,6 +1,8 @@
 package execution
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -66,3 +68,66 @@ func containsCLI(values []string, target string) bool {
 	}
 	return false
 }
+
+func TestSandboxCoversKotlinSwiftCSharpAndElixir(t *testing.T) {
+	t.Parallel()
+
+	sandbox := &ContainerSandbox{config: DefaultContainerSandboxConfig()}
+	profiles := containerLanguageToolchainProfiles(map[string]bool{"kotlin": true, "swift": true, "csharp": true, "elixir": true})
+	for language, want := range map[string]struct {
+		image string
+		cli   string
+	}{
+		"kotlin": {"eclipse-temurin:21-jdk-jammy", "kotlinc"},
+		"swift":  {"swift:5.10-jammy", "swiftc"},
+		"csharp": {"mcr.microsoft.com/dotnet/sdk:8.0-jammy", "dotnet"},
+		"elixir": {"elixir:1.17-slim", "mix"},
+	} {
+		if !strings.Contains(sandbox.generateDockerfile(language), "FROM "+want.image) {
+			t.Errorf("expected %s dockerfile to build from %s", language, want.image)
+		}
+		if sandbox.config.LanguageLimits[language] == nil {
+			t.Errorf("expected resource limits for %s", language)
+		}
+		if !containsCLI(profiles[language].AvailableCLIs, want.cli) {
+			t.Errorf("expected %s toolchain to include %q, got %+v", language, want.cli, profiles[language].AvailableCLIs)
+		}
+		if cmd := sandbox.getExecutionCommand(language, "main"); strings.Contains(strings.Join(cmd, " "), "Unsupported language") {
+			t.Errorf("expected an execution command for %s", language)
+		}
+		if runner, err := GetRunner(language); err != nil || runner.Language() != language {
+			t.Errorf("GetRunner(%q) = %v, %v", language, runner, err)
+		}
+	}
+	if !strings.Contains(sandbox.generateDockerfile("kotlin"), "kotlin-compiler-") {
+		t.Fatal("expected kotlin dockerfile to install kotlinc")
+	}
+	for alias, language := range map[string]string{"kt": "kotlin", "c#": "csharp", "dotnet": "csharp", "exs": "elixir", ".swift": "swift"} {
+		if runner, err := GetRunner(alias); err != nil || runner.Language() != language {
+			t.Errorf("GetRunner(%q) = %v, %v; want %s", alias, runner, err, language)
+		}
+	}
+}
+
+func TestWriteCodeFileAddsProjectForCSharp(t *testing.T) {
+	t.Parallel()
+
+	sandbox := &ContainerSandbox{config: DefaultContainerSandboxConfig()}
+	dir := t.TempDir()
+	filename, err := sandbox.writeCodeFile(dir, "csharp", `Console.WriteLine("hi");`)
+	if err != nil || filename != "Program.cs" {
+		t.Fatalf("writeCodeFile = %q, %v", filename, err)
+	}
+	project, err := os.ReadFile(filepath.Join(dir, "app.csproj"))
+	if err != nil || !strings.Contains(string(project), "<OutputType>Exe</OutputType>") {
+		t.Fatalf("expected console project next to Program.cs, got %q, %v", project, err)
+	}
+
+	filename, err = sandbox.writeCodeFile(dir, "kotlin", `println("hi")`)
+	if err != nil || filename != "main.kt" {
+		t.Fatalf("writeCodeFile = %q, %v", filename, err)
+	}
+	if code, _ := os.ReadFile(filepath.Join(dir, filename)); !strings.HasPrefix(string(code), "fun main()") {
+		t.Fatalf("expected kotlin snippet wrapped in main, got %q", code)
+	}
+}
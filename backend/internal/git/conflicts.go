@@ -0,0 +1,234 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Resolutions accepted by ResolveConflict
+const (
+	ResolveOurs   = "ours"   // keep the project copy
+	ResolveTheirs = "theirs" // take the remote copy
+	ResolveManual = "manual" // use caller-supplied content
+)
+
+var (
+	ErrConflictNotFound    = errors.New("conflict not found")
+	ErrUnresolvedConflicts = errors.New("unresolved conflicts remain")
+	ErrInvalidResolution   = errors.New("resolution must be ours, theirs or manual")
+)
+
+// ConflictDetail carries the three versions of a conflicted file and the
+// hunks where they disagree.
+type ConflictDetail struct {
+	*SyncConflict
+	Base   string         `json:"base"`
+	Ours   string         `json:"ours"`
+	Theirs string         `json:"theirs"`
+	Merged string         `json:"merged"` // auto-merged text with conflict markers
+	Hunks  []ConflictHunk `json:"hunks"`
+}
+
+// GetConflictDetails loads base, local and remote content for every
+// unresolved conflict and splits each file into conflict hunks.
+func (g *GitService) GetConflictDetails(ctx context.Context, projectID uint, token string) ([]*ConflictDetail, error) {
+	conflicts, err := g.GetSyncConflicts(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]*ConflictDetail, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		detail, err := g.conflictDetail(ctx, conflict, token)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
+
+// GetConflictDetail is GetConflictDetails for a single path
+func (g *GitService) GetConflictDetail(ctx context.Context, projectID uint, path, token string) (*ConflictDetail, error) {
+	conflict, err := g.findConflict(ctx, projectID, path)
+	if err != nil {
+		return nil, err
+	}
+	return g.conflictDetail(ctx, conflict, token)
+}
+
+func (g *GitService) conflictDetail(ctx context.Context, conflict *SyncConflict, token string) (*ConflictDetail, error) {
+	repo, err := g.GetRepository(ctx, conflict.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &ConflictDetail{SyncConflict: conflict}
+	if conflict.BaseSHA != "" {
+		if detail.Base, err = g.fetchGitHubBlob(ctx, repo, token, conflict.BaseSHA); err != nil {
+			return nil, fmt.Errorf("failed to fetch base of %s: %w", conflict.Path, err)
+		}
+	}
+	if conflict.RemoteSHA != "" {
+		if detail.Theirs, err = g.fetchGitHubBlob(ctx, repo, token, conflict.RemoteSHA); err != nil {
+			return nil, fmt.Errorf("failed to fetch remote %s: %w", conflict.Path, err)
+		}
+	}
+	var file models.File
+	if err := g.db.WithContext(ctx).Where("project_id = ? AND path = ?", conflict.ProjectID, conflict.Path).First(&file).Error; err == nil {
+		detail.Ours = file.Content
+		if ptr, ok := lfsPointerFor(&file, g.projectLFSAttributes(ctx, conflict.ProjectID)); ok {
+			detail.Ours = ptr.String()
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	merge := Merge3(detail.Base, detail.Ours, detail.Theirs)
+	detail.Merged = merge.Merged
+	detail.Hunks = merge.Hunks
+	return detail, nil
+}
+
+// ResolveConflict settles one conflicted path. The remote blob becomes the
+// new merge base, so the next sync treats whatever the project now holds as
+// a local change to push.
+func (g *GitService) ResolveConflict(ctx context.Context, projectID uint, path, resolution, content, token string) (*SyncConflict, error) {
+	conflict, err := g.findConflict(ctx, projectID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var remoteContent string
+	var remoteLFS *LFSPointer
+	switch resolution {
+	case ResolveOurs, ResolveManual:
+	case ResolveTheirs:
+		if conflict.RemoteSHA != "" {
+			repo, err := g.GetRepository(ctx, projectID)
+			if err != nil {
+				return nil, err
+			}
+			if remoteContent, err = g.fetchGitHubBlob(ctx, repo, token, conflict.RemoteSHA); err != nil {
+				return nil, fmt.Errorf("failed to fetch remote %s: %w", path, err)
+			}
+			remoteLFS = g.resolveLFSContent(ctx, g.lfsRemote(repo.RepoOwner, repo.RepoName, token), path, remoteContent)
+		}
+	default:
+		return nil, ErrInvalidResolution
+	}
+
+	err = g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing := map[string]models.File{}
+		var file models.File
+		if err := tx.Where("project_id = ? AND path = ?", projectID, path).First(&file).Error; err == nil {
+			existing[path] = file
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		switch {
+		case resolution == ResolveManual:
+			if err := g.writeSyncedFile(tx, projectID, path, content, nil, existing); err != nil {
+				return err
+			}
+		case resolution == ResolveTheirs && conflict.RemoteSHA == "":
+			if err := tx.Where("project_id = ? AND path = ?", projectID, path).Delete(&models.File{}).Error; err != nil {
+				return err
+			}
+		case resolution == ResolveTheirs:
+			if err := g.writeSyncedFile(tx, projectID, path, remoteContent, remoteLFS, existing); err != nil {
+				return err
+			}
+		}
+
+		if conflict.RemoteSHA != "" {
+			if err := setSyncBase(tx, projectID, path, conflict.RemoteSHA); err != nil {
+				return err
+			}
+		} else if err := tx.Where("project_id = ? AND path = ?", projectID, path).Delete(&SyncedFile{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		conflict.Resolution = resolution
+		conflict.ResolvedAt = &now
+		if err := tx.Model(conflict).Updates(map[string]interface{}{"resolution": resolution, "resolved_at": now}).Error; err != nil {
+			return err
+		}
+
+		var remaining int64
+		if err := tx.Model(&SyncConflict{}).Where("project_id = ? AND resolved_at IS NULL", projectID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Model(&Repository{}).Where("project_id = ?", projectID).Update("sync_status", SyncStatusSynced).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conflict, nil
+}
+
+// CommitResolution pushes the files resolved in favour of the project copy
+// (ours or manual) as a commit on the connected branch. It refuses while
+// any conflict is still open. A nil commit means nothing needed pushing.
+func (g *GitService) CommitResolution(ctx context.Context, projectID uint, message, token string) (*Commit, error) {
+	db := g.db.WithContext(ctx)
+	var remaining int64
+	if err := db.Model(&SyncConflict{}).Where("project_id = ? AND resolved_at IS NULL", projectID).Count(&remaining).Error; err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, ErrUnresolvedConflicts
+	}
+
+	var resolved []SyncConflict
+	if err := db.Where("project_id = ? AND resolution IN ?", projectID, []string{ResolveOurs, ResolveManual}).
+		Order("path").Find(&resolved).Error; err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, conflict := range resolved {
+		var count int64
+		db.Model(&models.File{}).Where("project_id = ? AND path = ?", projectID, conflict.Path).Count(&count)
+		if count > 0 {
+			paths = append(paths, conflict.Path)
+		}
+	}
+
+	var commit *Commit
+	if len(paths) > 0 {
+		if message == "" {
+			message = "Merge remote changes"
+		}
+		var err error
+		if commit, err = g.CreateCommit(ctx, projectID, message, paths, token); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.Where("project_id = ? AND resolved_at IS NOT NULL", projectID).Delete(&SyncConflict{}).Error; err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+func (g *GitService) findConflict(ctx context.Context, projectID uint, path string) (*SyncConflict, error) {
+	var conflict SyncConflict
+	err := g.db.WithContext(ctx).Where("project_id = ? AND path = ? AND resolved_at IS NULL", projectID, path).First(&conflict).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrConflictNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conflict, nil
+}
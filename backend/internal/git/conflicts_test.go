@@ -0,0 +1,80 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge3TakesNonOverlappingEditsAndReportsHunks(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+
+	clean := Merge3(base, "a\nB\nc\nd\ne\n", "a\nb\nc\nd\nE\n")
+	require.True(t, clean.Clean)
+	require.Equal(t, "a\nB\nc\nd\nE\n", clean.Merged)
+	require.Empty(t, clean.Hunks)
+
+	conflicted := Merge3(base, "a\nours\nc\nd\ne\n", "a\ntheirs\nc\nd\nE\n")
+	require.False(t, conflicted.Clean)
+	require.Len(t, conflicted.Hunks, 1)
+	hunk := conflicted.Hunks[0]
+	require.Equal(t, 2, hunk.BaseStart)
+	require.Equal(t, 1, hunk.BaseLines)
+	require.Equal(t, "b\n", hunk.Base)
+	require.Equal(t, "ours\n", hunk.Ours)
+	require.Equal(t, "theirs\n", hunk.Theirs)
+	require.Equal(t, "a\n<<<<<<< ours\nours\n=======\ntheirs\n>>>>>>> theirs\nc\nd\nE\n", conflicted.Merged)
+
+	same := Merge3(base, "a\nx\nc\nd\ne\n", "a\nx\nc\nd\ne\n")
+	require.True(t, same.Clean)
+	require.Equal(t, "a\nx\nc\nd\ne\n", same.Merged)
+}
+
+func TestSyncAutoMergesAndResolvesConflicts(t *testing.T) {
+	remote := map[string]string{
+		"merge.txt":    "one\ntwo\nthree\nFOUR\n",
+		"conflict.txt": "remote line\n",
+	}
+	service, db, projectID := newSyncTestFixture(t, remote, "one\ntwo\nthree\nfour\n", "base line\n")
+	seedSynced(t, db, projectID, map[string]string{
+		"merge.txt":    "one\ntwo\nthree\nfour\n",
+		"conflict.txt": "base line\n",
+	})
+	db.Exec("UPDATE files SET content = ? WHERE path = ?", "ONE\ntwo\nthree\nfour\n", "merge.txt")
+	db.Exec("UPDATE files SET content = ? WHERE path = ?", "local line\n", "conflict.txt")
+
+	ctx := context.Background()
+	result, err := service.SyncFromRemote(ctx, projectID, "token", "abc123")
+	require.NoError(t, err)
+	require.Equal(t, []string{"merge.txt"}, result.Merged)
+	require.Equal(t, "ONE\ntwo\nthree\nFOUR\n", fileContent(t, db, projectID, "merge.txt"))
+	require.Len(t, result.Conflicts, 1)
+
+	detail, err := service.GetConflictDetail(ctx, projectID, "conflict.txt", "token")
+	require.NoError(t, err)
+	require.Equal(t, "base line\n", detail.Base)
+	require.Equal(t, "local line\n", detail.Ours)
+	require.Equal(t, "remote line\n", detail.Theirs)
+	require.Len(t, detail.Hunks, 1)
+
+	_, err = service.ResolveConflict(ctx, projectID, "conflict.txt", "both", "", "token")
+	require.ErrorIs(t, err, ErrInvalidResolution)
+
+	_, err = service.ResolveConflict(ctx, projectID, "conflict.txt", ResolveManual, "local line\nremote line\n", "token")
+	require.NoError(t, err)
+	require.Equal(t, "local line\nremote line\n", fileContent(t, db, projectID, "conflict.txt"))
+
+	open, err := service.GetSyncConflicts(ctx, projectID)
+	require.NoError(t, err)
+	require.Empty(t, open)
+	repo, err := service.GetRepository(ctx, projectID)
+	require.NoError(t, err)
+	require.Equal(t, SyncStatusSynced, repo.SyncStatus)
+
+	// The remote blob is now the base: a re-sync leaves the manual merge alone.
+	result, err = service.SyncFromRemote(ctx, projectID, "token", "abc123")
+	require.NoError(t, err)
+	require.Empty(t, result.Conflicts)
+	require.Equal(t, "local line\nremote line\n", fileContent(t, db, projectID, "conflict.txt"))
+}
@@ -8,11 +8,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"apex-build/internal/secrets"
+	"apex-build/internal/storage"
 	"apex-build/pkg/models"
 
 	"gorm.io/gorm"
@@ -22,7 +25,12 @@ import (
 type GitService struct {
 	db          *gorm.DB
 	githubToken string // Server-level GitHub token (optional)
+	apiBase     string // GitHub API root; empty means api.github.com
+	lfsBase     string // Git LFS server root; empty means github.com
+	blobs       storage.Provider
+	secrets     *secrets.SecretsManager // decrypts commit signing keys
 	mu          sync.RWMutex
+	syncing     map[uint]*pendingSync // in-flight auto-syncs and their queued follow-up
 }
 
 // Repository represents a git repository configuration
@@ -36,8 +44,20 @@ type Repository struct {
 	Branch      string    `json:"branch"`
 	LastSync    time.Time `json:"last_sync"`
 	IsConnected bool      `json:"is_connected"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Webhook-driven sync from the connected branch
+	AutoSync      bool   `json:"auto_sync" gorm:"default:false"`
+	WebhookID     int64  `json:"webhook_id,omitempty"`
+	WebhookSecret string `json:"-"`
+	SyncStatus    string `json:"sync_status" gorm:"default:'idle'"` // idle, syncing, synced, conflict, error
+	SyncError     string `json:"sync_error,omitempty"`
+	LastSyncedSHA string `json:"last_synced_sha,omitempty"`
+
+	// Sign commits made by the platform with the owner's GIT_SIGNING_KEY
+	SignCommits bool `json:"sign_commits" gorm:"default:false"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // Commit represents a git commit
@@ -48,6 +68,8 @@ type Commit struct {
 	Email     string    `json:"email"`
 	Timestamp time.Time `json:"timestamp"`
 	Files     []string  `json:"files,omitempty"`
+	Signed    bool      `json:"signed,omitempty"`
+	Verified  bool      `json:"verified,omitempty"` // GitHub accepted the signature
 }
 
 // Branch represents a git branch
@@ -232,7 +254,7 @@ func (g *GitService) CreateCommit(ctx context.Context, projectID uint, message s
 
 	switch repo.Provider {
 	case "github":
-		return g.createGitHubCommit(ctx, repo, message, files, token, projectID)
+		return g.createGitHubCommit(ctx, repo, message, files, nil, token, projectID, true)
 	default:
 		return nil, fmt.Errorf("provider not supported: %s", repo.Provider)
 	}
@@ -427,7 +449,9 @@ func (g *GitService) getGitHubCommits(ctx context.Context, repo *Repository, bra
 	return commits, nil
 }
 
-func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, message string, filePaths []string, token string, projectID uint) (*Commit, error) {
+// createGitHubCommit commits project files to repo.Branch. Paths present in
+// contents are committed with that text instead of the stored file.
+func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, message string, filePaths []string, contents map[string]string, token string, projectID uint, recordSyncBases bool) (*Commit, error) {
 	// Step 1: Get the latest commit SHA
 	refURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/ref/heads/%s",
 		repo.RepoOwner, repo.RepoName, repo.Branch)
@@ -478,18 +502,27 @@ func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, m
 
 	baseTreeSHA := commit.Tree.SHA
 
-	// Step 3: Create blobs for each file
+	// Step 3: Create blobs for each file. Paths tracked by .gitattributes go
+	// to the LFS server and are committed as pointers.
+	attrs := g.projectLFSAttributes(ctx, projectID)
+	lfsRemote := g.lfsRemote(repo.RepoOwner, repo.RepoName, token)
 	var treeEntries []map[string]interface{}
 	for _, path := range filePaths {
-		var file models.File
-		if err := g.db.WithContext(ctx).Where("project_id = ? AND path = ?", projectID, path).First(&file).Error; err != nil {
+		file := models.File{Path: path}
+		if text, ok := contents[path]; ok {
+			file.Content = text
+		} else if err := g.db.WithContext(ctx).Where("project_id = ? AND path = ?", projectID, path).First(&file).Error; err != nil {
 			continue
 		}
+		content, err := g.gitBlobContent(ctx, lfsRemote, &file, attrs)
+		if err != nil {
+			return nil, err
+		}
 
 		// Create blob
 		blobURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/blobs", repo.RepoOwner, repo.RepoName)
 		blobData := map[string]string{
-			"content":  base64.StdEncoding.EncodeToString([]byte(file.Content)),
+			"content":  base64.StdEncoding.EncodeToString(content),
 			"encoding": "base64",
 		}
 		blobJSON, _ := json.Marshal(blobData)
@@ -544,13 +577,22 @@ func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, m
 		return nil, err
 	}
 
-	// Step 5: Create commit
+	// Step 5: Create commit, signed when the repository asks for it
 	createCommitURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/commits", repo.RepoOwner, repo.RepoName)
 	commitData := map[string]interface{}{
 		"message": message,
 		"tree":    tree.SHA,
 		"parents": []string{baseSHA},
 	}
+	author, err := g.commitAuthorFor(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if author != nil {
+		if err := author.sign(commitData, tree.SHA, []string{baseSHA}, message, time.Now()); err != nil {
+			return nil, err
+		}
+	}
 	commitJSON, _ := json.Marshal(commitData)
 
 	req, _ = http.NewRequestWithContext(ctx, "POST", createCommitURL, strings.NewReader(string(commitJSON)))
@@ -571,6 +613,9 @@ func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, m
 			Email string    `json:"email"`
 			Date  time.Time `json:"date"`
 		} `json:"author"`
+		Verification struct {
+			Verified bool `json:"verified"`
+		} `json:"verification"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&newCommit); err != nil {
 		return nil, err
@@ -595,6 +640,24 @@ func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, m
 		return nil, err
 	}
 	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to update branch %s: github returned %d", repo.Branch, resp.StatusCode)
+	}
+
+	// The pushed blobs now match the remote; move their merge bases so the
+	// resulting push webhook does not report them as conflicts.
+	if recordSyncBases {
+		if err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, entry := range treeEntries {
+				if err := setSyncBase(tx, projectID, entry["path"].(string), entry["sha"].(string)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			log.Printf("git: failed to record sync bases for project %d: %v", projectID, err)
+		}
+	}
 
 	return &Commit{
 		SHA:       newCommit.SHA,
@@ -603,87 +666,36 @@ func (g *GitService) createGitHubCommit(ctx context.Context, repo *Repository, m
 		Email:     newCommit.Author.Email,
 		Timestamp: newCommit.Author.Date,
 		Files:     filePaths,
+		Signed:    author != nil,
+		Verified:  newCommit.Verification.Verified,
 	}, nil
 }
 
 func (g *GitService) pullFromGitHub(ctx context.Context, repo *Repository, token string, projectID uint) error {
 	// Get the tree for the current branch
-	treeURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/trees/%s?recursive=1",
-		repo.RepoOwner, repo.RepoName, repo.Branch)
-
-	req, _ := http.NewRequestWithContext(ctx, "GET", treeURL, nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	resp, err := http.DefaultClient.Do(req)
+	entries, err := g.fetchGitHubTree(ctx, repo, token, repo.Branch)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("failed to fetch repository tree: github returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-
-	var tree struct {
-		Tree []struct {
-			Path string `json:"path"`
-			Type string `json:"type"`
-			SHA  string `json:"sha"`
-		} `json:"tree"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
-		return err
-	}
 
-	remotePaths := make(map[string]struct{}, len(tree.Tree))
+	remotePaths := make(map[string]struct{}, len(entries))
+	bases := make(map[string]string, len(entries))
+	lfsRemote := g.lfsRemote(repo.RepoOwner, repo.RepoName, token)
 
 	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		for _, item := range tree.Tree {
+		for _, item := range entries {
 			remotePaths[item.Path] = struct{}{}
 
 			if item.Type != "blob" {
 				continue
 			}
 
-			blobURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/blobs/%s",
-				repo.RepoOwner, repo.RepoName, item.SHA)
-
-			req, _ := http.NewRequestWithContext(ctx, "GET", blobURL, nil)
-			req.Header.Set("Authorization", "Bearer "+token)
-			req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-			resp, err := http.DefaultClient.Do(req)
+			content, err := g.fetchGitHubBlob(ctx, repo, token, item.SHA)
 			if err != nil {
-				return err
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-				resp.Body.Close()
-				return fmt.Errorf("failed to fetch blob %s: github returned %d: %s", item.Path, resp.StatusCode, strings.TrimSpace(string(body)))
-			}
-
-			var blob struct {
-				Content  string `json:"content"`
-				Encoding string `json:"encoding"`
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&blob); err != nil {
-				resp.Body.Close()
-				return err
-			}
-			resp.Body.Close()
-
-			var content string
-			if blob.Encoding == "base64" {
-				decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(blob.Content, "\n", ""))
-				if err != nil {
-					return fmt.Errorf("failed to decode blob %s: %w", item.Path, err)
-				}
-				content = string(decoded)
-			} else {
-				content = blob.Content
+				return fmt.Errorf("failed to fetch blob %s: %w", item.Path, err)
 			}
+			bases[item.Path] = item.SHA
+			lfs := g.resolveLFSContent(ctx, lfsRemote, item.Path, content)
 
 			var file models.File
 			result := tx.Where("project_id = ? AND path = ?", projectID, item.Path).First(&file)
@@ -695,21 +707,19 @@ func (g *GitService) pullFromGitHub(ctx context.Context, repo *Repository, token
 					ProjectID: projectID,
 					Name:      g.getFileName(item.Path),
 					Path:      item.Path,
-					Content:   content,
-					Size:      int64(len(content)),
 					MimeType:  g.getMimeType(item.Path),
 					Version:   1,
 					CreatedAt: time.Now(),
 					UpdatedAt: time.Now(),
 				}
+				setGitContent(&file, content, lfs)
 				if err := tx.Create(&file).Error; err != nil {
 					return err
 				}
 				continue
 			}
 
-			file.Content = content
-			file.Size = int64(len(content))
+			setGitContent(&file, content, lfs)
 			file.UpdatedAt = time.Now()
 			if err := tx.Save(&file).Error; err != nil {
 				return err
@@ -730,7 +740,15 @@ func (g *GitService) pullFromGitHub(ctx context.Context, repo *Repository, token
 			}
 		}
 
+		// A full pull leaves the project identical to the remote, so every
+		// pulled blob becomes the merge base for later auto-syncs.
+		if err := replaceSyncBases(tx, projectID, bases); err != nil {
+			return err
+		}
+
 		repo.LastSync = time.Now()
+		repo.SyncStatus = SyncStatusSynced
+		repo.SyncError = ""
 		return tx.Save(repo).Error
 	})
 }
@@ -919,10 +937,13 @@ type ExportResult struct {
 	CommitSHA string `json:"commit_sha"`
 	Branch    string `json:"branch"`
 	FileCount int    `json:"file_count"`
+	Signed    bool   `json:"signed"`
 }
 
 // ExportToGitHub creates a new GitHub repository and pushes all project files to it
-func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoName, description, token string, isPrivate bool) (*ExportResult, error) {
+// With signCommits the initial commit is signed with the owner's
+// GIT_SIGNING_KEY and the connected repository keeps signing later commits.
+func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoName, description, token string, isPrivate, signCommits bool) (*ExportResult, error) {
 	// Step 1: Get all project files from the database
 	var files []models.File
 	if err := g.db.WithContext(ctx).Where("project_id = ?", projectID).Find(&files).Error; err != nil {
@@ -933,6 +954,15 @@ func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoNam
 		return nil, fmt.Errorf("project has no files to export")
 	}
 
+	var author *commitAuthor
+	if signCommits {
+		signer, name, email, err := g.projectSigner(ctx, projectID)
+		if err != nil {
+			return nil, fmt.Errorf("commit signing requested: %w", err)
+		}
+		author = &commitAuthor{signer: signer, name: name, email: email}
+	}
+
 	// Step 2: Get the authenticated user's GitHub username
 	owner, err := g.getGitHubUser(ctx, token)
 	if err != nil {
@@ -948,9 +978,11 @@ func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoNam
 	// We need to build: blobs → tree → commit → update ref (creating the default branch)
 	var treeEntries []map[string]interface{}
 	fileCount := 0
+	attrs := g.projectLFSAttributes(ctx, projectID)
+	lfsRemote := g.lfsRemote(owner, repoName, token)
 
 	for _, file := range files {
-		if file.Type == "directory" || file.Content == "" {
+		if file.Type == "directory" || (file.Content == "" && !file.IsBinary) {
 			continue
 		}
 
@@ -958,11 +990,15 @@ func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoNam
 		if len(path) > 0 && path[0] == '/' {
 			path = path[1:]
 		}
+		content, err := g.gitBlobContent(ctx, lfsRemote, &file, attrs)
+		if err != nil {
+			return nil, err
+		}
 
 		// Create blob
 		blobURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/blobs", owner, repoName)
 		blobData := map[string]string{
-			"content":  base64.StdEncoding.EncodeToString([]byte(file.Content)),
+			"content":  base64.StdEncoding.EncodeToString(content),
 			"encoding": "base64",
 		}
 		blobJSON, _ := json.Marshal(blobData)
@@ -1032,11 +1068,17 @@ func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoNam
 
 	// Create initial commit (no parents)
 	commitURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/commits", owner, repoName)
+	commitMessage := "Initial commit — exported from APEX.BUILD"
 	commitData := map[string]interface{}{
-		"message": "Initial commit — exported from APEX.BUILD",
+		"message": commitMessage,
 		"tree":    tree.SHA,
 		"parents": []string{},
 	}
+	if author != nil {
+		if err := author.sign(commitData, tree.SHA, nil, commitMessage, time.Now()); err != nil {
+			return nil, err
+		}
+	}
 	commitJSON, _ := json.Marshal(commitData)
 
 	commitReq, _ := http.NewRequestWithContext(ctx, "POST", commitURL, strings.NewReader(string(commitJSON)))
@@ -1091,6 +1133,7 @@ func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoNam
 		Branch:      "main",
 		LastSync:    time.Now(),
 		IsConnected: true,
+		SignCommits: signCommits,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -1103,6 +1146,7 @@ func (g *GitService) ExportToGitHub(ctx context.Context, projectID uint, repoNam
 		CommitSHA: newCommit.SHA,
 		Branch:    "main",
 		FileCount: fileCount,
+		Signed:    author != nil,
 	}, nil
 }
 
@@ -0,0 +1,262 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Issue build statuses
+const (
+	IssueBuildCompleted = "completed"  // changes applied to the project
+	IssueBuildNoChanges = "no_changes" // the Solver found nothing to change
+	IssueBuildFailed    = "failed"
+)
+
+// maxIssueComments bounds how much of a discussion is pulled into a build
+const maxIssueComments = 30
+
+var (
+	// ErrInvalidIssueURL is returned for links that are not a GitHub issue
+	ErrInvalidIssueURL = errors.New("expected a GitHub issue URL like https://github.com/owner/repo/issues/42")
+	// ErrIssueRepoMismatch is returned when the issue belongs to a repository
+	// other than the project's connected one
+	ErrIssueRepoMismatch = errors.New("issue does not belong to the project's connected repository")
+	// ErrIssueNotFound is returned when GitHub has no such issue, or it is a
+	// pull request
+	ErrIssueNotFound = errors.New("issue not found")
+)
+
+// Issue is a GitHub issue with its discussion
+type Issue struct {
+	Number   int            `json:"number"`
+	Title    string         `json:"title"`
+	Body     string         `json:"body"`
+	State    string         `json:"state"`
+	Author   string         `json:"author"`
+	Labels   []string       `json:"labels,omitempty"`
+	URL      string         `json:"url"`
+	Comments []IssueComment `json:"comments,omitempty"`
+}
+
+// IssueComment is one comment on an issue
+type IssueComment struct {
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IssueBuild records a change-request build created from a GitHub issue
+type IssueBuild struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	ProjectID         uint      `json:"project_id" gorm:"index:idx_git_issue_builds_project_created"`
+	UserID            uint      `json:"user_id" gorm:"index"`
+	IssueNumber       int       `json:"issue_number"`
+	IssueURL          string    `json:"issue_url"`
+	IssueTitle        string    `json:"issue_title"`
+	Status            string    `json:"status" gorm:"type:varchar(20)"`
+	Summary           string    `json:"summary,omitempty" gorm:"type:text"`
+	ChangedFiles      []string  `json:"changed_files" gorm:"serializer:json"`
+	ContextFiles      []string  `json:"context_files" gorm:"serializer:json"`
+	Provider          string    `json:"provider,omitempty"`
+	Branch            string    `json:"branch,omitempty"`
+	PullRequestNumber int       `json:"pull_request_number,omitempty"`
+	PullRequestURL    string    `json:"pull_request_url,omitempty"`
+	Error             string    `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt         time.Time `json:"created_at" gorm:"index:idx_git_issue_builds_project_created"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for IssueBuild
+func (IssueBuild) TableName() string { return "git_issue_builds" }
+
+// ParseIssueURL extracts the repository and number from a GitHub issue
+// link. "owner/repo#42" is accepted as shorthand.
+func ParseIssueURL(raw string) (owner, name string, number int, err error) {
+	raw = strings.TrimSpace(raw)
+	if repo, num, ok := strings.Cut(raw, "#"); ok && !strings.Contains(raw, "://") {
+		owner, name, _ = strings.Cut(repo, "/")
+		number, err = strconv.Atoi(num)
+		if err != nil || number <= 0 || owner == "" || name == "" || strings.Contains(name, "/") {
+			return "", "", 0, ErrInvalidIssueURL
+		}
+		return owner, name, number, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || !strings.EqualFold(strings.TrimPrefix(u.Host, "www."), "github.com") {
+		return "", "", 0, ErrInvalidIssueURL
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "issues" {
+		return "", "", 0, ErrInvalidIssueURL
+	}
+	number, err = strconv.Atoi(parts[3])
+	if err != nil || number <= 0 || parts[0] == "" || parts[1] == "" {
+		return "", "", 0, ErrInvalidIssueURL
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), number, nil
+}
+
+// IssueForProject checks that issueURL points at the project's connected
+// GitHub repository and fetches the issue with its comments.
+func (g *GitService) IssueForProject(ctx context.Context, projectID uint, issueURL, token string) (*Repository, *Issue, error) {
+	owner, name, number, err := ParseIssueURL(issueURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo, err := g.GetRepository(ctx, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if repo.Provider != "github" {
+		return nil, nil, fmt.Errorf("provider not supported: %s", repo.Provider)
+	}
+	if !strings.EqualFold(owner, repo.RepoOwner) || !strings.EqualFold(name, repo.RepoName) {
+		return nil, nil, ErrIssueRepoMismatch
+	}
+
+	issue, err := g.getGitHubIssue(ctx, repo, number, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, issue, nil
+}
+
+func (g *GitService) getGitHubIssue(ctx context.Context, repo *Repository, number int, token string) (*Issue, error) {
+	var raw struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+		State  string `json:"state"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		HTMLURL     string          `json:"html_url"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	}
+	err := g.githubJSON(ctx, http.MethodGet, g.githubURL("/repos/%s/%s/issues/%d", repo.RepoOwner, repo.RepoName, number), token, nil, &raw)
+	if err != nil {
+		if isGitHubNotFound(err) {
+			return nil, ErrIssueNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch issue #%d: %w", number, err)
+	}
+	if len(raw.PullRequest) > 0 && string(raw.PullRequest) != "null" {
+		return nil, ErrIssueNotFound
+	}
+
+	issue := &Issue{
+		Number: raw.Number,
+		Title:  raw.Title,
+		Body:   raw.Body,
+		State:  raw.State,
+		Author: raw.User.Login,
+		URL:    raw.HTMLURL,
+	}
+	for _, label := range raw.Labels {
+		issue.Labels = append(issue.Labels, label.Name)
+	}
+
+	var comments []struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	err = g.githubJSON(ctx, http.MethodGet, g.githubURL("/repos/%s/%s/issues/%d/comments?per_page=%d", repo.RepoOwner, repo.RepoName, number, maxIssueComments), token, nil, &comments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments for issue #%d: %w", number, err)
+	}
+	for _, comment := range comments {
+		issue.Comments = append(issue.Comments, IssueComment{Author: comment.User.Login, Body: comment.Body, CreatedAt: comment.CreatedAt})
+	}
+	return issue, nil
+}
+
+// WriteProjectFiles creates or overwrites text files in the project
+func (g *GitService) WriteProjectFiles(ctx context.Context, projectID uint, files map[string]string) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []models.File
+		if err := tx.Where("project_id = ?", projectID).Find(&existing).Error; err != nil {
+			return err
+		}
+		byPath := make(map[string]models.File, len(existing))
+		for _, file := range existing {
+			byPath[file.Path] = file
+		}
+		for path, content := range files {
+			if err := g.writeSyncedFile(tx, projectID, path, content, nil, byPath); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// CommitToBranch commits project files to a branch other than the connected
+// one, e.g. a pull request branch. Merge bases are left alone because they
+// track the connected branch.
+func (g *GitService) CommitToBranch(ctx context.Context, projectID uint, branch, message string, files []string, token string) (*Commit, error) {
+	repo, err := g.GetRepository(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Provider != "github" {
+		return nil, fmt.Errorf("provider not supported: %s", repo.Provider)
+	}
+	target := *repo
+	target.Branch = branch
+	return g.createGitHubCommit(ctx, &target, message, files, nil, token, projectID, branch == repo.Branch)
+}
+
+// CommitContentsToBranch commits the given file contents to a branch other
+// than the connected one without touching the stored project files.
+func (g *GitService) CommitContentsToBranch(ctx context.Context, projectID uint, branch, message string, files map[string]string, token string) (*Commit, error) {
+	repo, err := g.GetRepository(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Provider != "github" {
+		return nil, fmt.Errorf("provider not supported: %s", repo.Provider)
+	}
+	if branch == repo.Branch {
+		return nil, fmt.Errorf("refusing to commit unsaved contents to the connected branch %s", branch)
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	target := *repo
+	target.Branch = branch
+	return g.createGitHubCommit(ctx, &target, message, paths, files, token, projectID, false)
+}
+
+// ListIssueBuilds returns the project's most recent issue builds
+func (g *GitService) ListIssueBuilds(ctx context.Context, projectID uint, limit int) ([]IssueBuild, error) {
+	var builds []IssueBuild
+	err := g.db.WithContext(ctx).Where("project_id = ?", projectID).
+		Order("created_at DESC, id DESC").Limit(limit).Find(&builds).Error
+	return builds, err
+}
+
+// SaveIssueBuild stores an issue build record
+func (g *GitService) SaveIssueBuild(ctx context.Context, build *IssueBuild) error {
+	return g.db.WithContext(ctx).Save(build).Error
+}
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueURL(t *testing.T) {
+	owner, name, number, err := ParseIssueURL("https://github.com/acme/site/issues/42#issuecomment-1")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"acme", "site", 42}, []interface{}{owner, name, number})
+
+	owner, name, number, err = ParseIssueURL("acme/site#7")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"acme", "site", 7}, []interface{}{owner, name, number})
+
+	for _, bad := range []string{
+		"https://github.com/acme/site/pull/42",
+		"https://gitlab.com/acme/site/issues/42",
+		"https://github.com/acme/site/issues/0",
+		"https://github.com/acme/site/issues",
+		"acme#3",
+		"",
+	} {
+		_, _, _, err := ParseIssueURL(bad)
+		require.ErrorIs(t, err, ErrInvalidIssueURL, bad)
+	}
+}
+
+func TestIssueForProjectFetchesIssueAndComments(t *testing.T) {
+	service, db, projectID := newSyncTestFixture(t, nil)
+	require.NoError(t, db.AutoMigrate(&IssueBuild{}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/site/issues/42":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"number":   42,
+				"title":    "Login button does nothing",
+				"body":     "Clicking it in src/Login.jsx has no effect",
+				"state":    "open",
+				"user":     map[string]string{"login": "reporter"},
+				"labels":   []map[string]string{{"name": "bug"}},
+				"html_url": "https://github.com/acme/site/issues/42",
+			})
+		case "/repos/acme/site/issues/42/comments":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"body": "Same on mobile", "user": map[string]string{"login": "second"}},
+			})
+		case "/repos/acme/site/issues/43":
+			json.NewEncoder(w).Encode(map[string]interface{}{"number": 43, "pull_request": map[string]string{"url": "x"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	service.apiBase = server.URL
+	ctx := context.Background()
+
+	repo, issue, err := service.IssueForProject(ctx, projectID, "https://github.com/ACME/site/issues/42", "token")
+	require.NoError(t, err)
+	require.Equal(t, "main", repo.Branch)
+	require.Equal(t, "Login button does nothing", issue.Title)
+	require.Equal(t, []string{"bug"}, issue.Labels)
+	require.Len(t, issue.Comments, 1)
+	require.Equal(t, "second", issue.Comments[0].Author)
+
+	_, _, err = service.IssueForProject(ctx, projectID, "https://github.com/other/site/issues/42", "token")
+	require.ErrorIs(t, err, ErrIssueRepoMismatch)
+	_, _, err = service.IssueForProject(ctx, projectID, "acme/site#43", "token")
+	require.ErrorIs(t, err, ErrIssueNotFound, "pull requests are not issues")
+	_, _, err = service.IssueForProject(ctx, projectID, "acme/site#99", "token")
+	require.ErrorIs(t, err, ErrIssueNotFound)
+}
+
+func TestWriteProjectFilesAndIssueBuilds(t *testing.T) {
+	service, db, projectID := newSyncTestFixture(t, nil)
+	require.NoError(t, db.AutoMigrate(&IssueBuild{}))
+	seedSynced(t, db, projectID, map[string]string{"src/app.js": "old\n"})
+	ctx := context.Background()
+
+	require.NoError(t, service.WriteProjectFiles(ctx, projectID, map[string]string{
+		"src/app.js": "new\n",
+		"src/fix.js": "added\n",
+	}))
+	require.Equal(t, "new\n", fileContent(t, db, projectID, "src/app.js"))
+	require.Equal(t, "added\n", fileContent(t, db, projectID, "src/fix.js"))
+
+	var count int64
+	db.Model(&models.File{}).Where("project_id = ?", projectID).Count(&count)
+	require.EqualValues(t, 2, count)
+
+	// Writing files does not move merge bases; only commits to the
+	// connected branch do.
+	var base SyncedFile
+	require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "src/app.js").First(&base).Error)
+	require.Equal(t, BlobSHA("old\n"), base.BlobSHA)
+
+	require.NoError(t, service.SaveIssueBuild(ctx, &IssueBuild{ProjectID: projectID, IssueNumber: 1, Status: IssueBuildNoChanges}))
+	require.NoError(t, service.SaveIssueBuild(ctx, &IssueBuild{ProjectID: projectID, IssueNumber: 2, Status: IssueBuildCompleted, ChangedFiles: []string{"src/app.js"}}))
+	builds, err := service.ListIssueBuilds(ctx, projectID, 10)
+	require.NoError(t, err)
+	require.Len(t, builds, 2)
+	require.Equal(t, 2, builds[0].IssueNumber)
+	require.Equal(t, []string{"src/app.js"}, builds[0].ChangedFiles)
+}
@@ -0,0 +1,452 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+)
+
+const (
+	lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+	lfsMediaType      = "application/vnd.git-lfs+json"
+
+	// maxLFSPointerSize is the spec's upper bound for a pointer file
+	maxLFSPointerSize = 1024
+	// maxLFSObjectSize bounds a single object pulled into the blob store
+	maxLFSObjectSize = 5 << 30
+)
+
+var errLFSStoreUnavailable = errors.New("blob store not configured for git lfs objects")
+
+// LFSPointer is the small text file git commits in place of an LFS object.
+// OID is the SHA-256 of the content, the same digest the blob store keys on.
+type LFSPointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// ParseLFSPointer recognizes a Git LFS pointer file
+func ParseLFSPointer(content string) (LFSPointer, bool) {
+	if len(content) > maxLFSPointerSize || !strings.HasPrefix(content, lfsPointerVersion+"\n") {
+		return LFSPointer{}, false
+	}
+	var ptr LFSPointer
+	sizeSeen := false
+	for _, line := range strings.Split(content, "\n")[1:] {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			ptr.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return LFSPointer{}, false
+			}
+			ptr.Size, sizeSeen = size, true
+		}
+	}
+	if !storage.ValidBlobHash(ptr.OID) || !sizeSeen {
+		return LFSPointer{}, false
+	}
+	return ptr, true
+}
+
+// String renders the canonical pointer file, so equal objects always
+// produce the same git blob.
+func (p LFSPointer) String() string {
+	return fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsPointerVersion, p.OID, p.Size)
+}
+
+// LFSAttributes holds the paths .gitattributes routes through LFS
+type LFSAttributes struct {
+	patterns []*regexp.Regexp
+	sources  []string
+}
+
+// ParseLFSAttributes reads the `filter=lfs` patterns from a .gitattributes
+// file. A later `-filter` or `!filter` line for the same pattern unsets it.
+func ParseLFSAttributes(content string) LFSAttributes {
+	var attrs LFSAttributes
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pattern := fields[0]
+		for _, attr := range fields[1:] {
+			switch {
+			case attr == "filter=lfs":
+				attrs.add(pattern)
+			case attr == "-filter", attr == "!filter", strings.HasPrefix(attr, "filter="):
+				attrs.remove(pattern)
+			}
+		}
+	}
+	return attrs
+}
+
+func (a *LFSAttributes) add(pattern string) {
+	a.remove(pattern)
+	if re, err := gitattributesPattern(pattern); err == nil {
+		a.patterns = append(a.patterns, re)
+		a.sources = append(a.sources, pattern)
+	}
+}
+
+func (a *LFSAttributes) remove(pattern string) {
+	for i, source := range a.sources {
+		if source == pattern {
+			a.patterns = append(a.patterns[:i], a.patterns[i+1:]...)
+			a.sources = append(a.sources[:i], a.sources[i+1:]...)
+			return
+		}
+	}
+}
+
+// Tracks reports whether path is stored in LFS
+func (a LFSAttributes) Tracks(filePath string) bool {
+	filePath = strings.TrimPrefix(filePath, "/")
+	for i, re := range a.patterns {
+		target := filePath
+		if !strings.Contains(a.sources[i], "/") {
+			target = path.Base(filePath)
+		}
+		if re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Patterns lists the tracked patterns in file order
+func (a LFSAttributes) Patterns() []string {
+	return append([]string(nil), a.sources...)
+}
+
+// gitattributesPattern converts a gitattributes glob to an anchored regexp.
+// Patterns without a slash match the file name; others match from the root.
+func gitattributesPattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(pattern, "/")
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			b.WriteString("/.*")
+			i += 2
+		case ch == '*':
+			b.WriteString("[^/]*")
+		case ch == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// LFSRemote addresses a repository's Git LFS server
+type LFSRemote struct {
+	Endpoint string // e.g. https://github.com/owner/repo.git/info/lfs
+	Username string
+	Password string
+}
+
+// NewLFSRemote derives the LFS endpoint of a repository from its clone URL
+func NewLFSRemote(repoURL, username, password string) LFSRemote {
+	return LFSRemote{
+		Endpoint: strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git") + ".git/info/lfs",
+		Username: username,
+		Password: password,
+	}
+}
+
+// GitHubLFSRemote returns the LFS endpoint of a GitHub repository
+func GitHubLFSRemote(owner, repo, token string) LFSRemote {
+	return NewLFSRemote(fmt.Sprintf("https://github.com/%s/%s", owner, repo), "x-access-token", token)
+}
+
+func (g *GitService) lfsRemote(owner, repo, token string) LFSRemote {
+	remote := GitHubLFSRemote(owner, repo, token)
+	if g.lfsBase != "" {
+		remote.Endpoint = fmt.Sprintf("%s/%s/%s.git/info/lfs", g.lfsBase, owner, repo)
+	}
+	return remote
+}
+
+// SetBlobStore wires the content-addressed store LFS objects are kept in
+func (g *GitService) SetBlobStore(p storage.Provider) {
+	g.blobs = p
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+type lfsBatchObject struct {
+	OID     string               `json:"oid"`
+	Size    int64                `json:"size"`
+	Actions map[string]lfsAction `json:"actions"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// lfsBatch runs the LFS batch API for one object
+func (g *GitService) lfsBatch(ctx context.Context, remote LFSRemote, operation string, ptr LFSPointer) (*lfsBatchObject, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"operation": operation,
+		"transfers": []string{"basic"},
+		"objects":   []LFSPointer{ptr},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, remote.Endpoint+"/objects/batch", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	if remote.Password != "" {
+		req.SetBasicAuth(remote.Username, remote.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("lfs batch %s returned %d: %s", operation, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var batch struct {
+		Objects []lfsBatchObject `json:"objects"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, err
+	}
+	for i := range batch.Objects {
+		object := &batch.Objects[i]
+		if object.OID != ptr.OID {
+			continue
+		}
+		if object.Error != nil {
+			return nil, fmt.Errorf("lfs object %s: %s", ptr.OID, object.Error.Message)
+		}
+		return object, nil
+	}
+	return nil, fmt.Errorf("lfs batch response is missing object %s", ptr.OID)
+}
+
+func lfsRequest(ctx context.Context, method string, action lfsAction, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, action.Href, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// FetchLFSObject downloads the object a pointer refers to into the blob
+// store. Objects already stored are not downloaded again.
+func (g *GitService) FetchLFSObject(ctx context.Context, remote LFSRemote, ptr LFSPointer) error {
+	if g.blobs == nil {
+		return errLFSStoreUnavailable
+	}
+	if exists, err := g.blobs.Exists(ctx, storage.BlobKey(ptr.OID)); err == nil && exists {
+		return nil
+	}
+
+	object, err := g.lfsBatch(ctx, remote, "download", ptr)
+	if err != nil {
+		return err
+	}
+	download, ok := object.Actions["download"]
+	if !ok {
+		return fmt.Errorf("lfs server offered no download for %s", ptr.OID)
+	}
+	req, err := lfsRequest(ctx, http.MethodGet, download, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lfs download of %s returned %d", ptr.OID, resp.StatusCode)
+	}
+
+	hash, size, err := storage.PutBlob(ctx, g.blobs, resp.Body, min(ptr.Size, maxLFSObjectSize), "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	if hash != ptr.OID || size != ptr.Size {
+		return fmt.Errorf("lfs object %s failed verification", ptr.OID)
+	}
+	return nil
+}
+
+// PushLFSObject uploads a blob-store object to the LFS server unless the
+// server already has it.
+func (g *GitService) PushLFSObject(ctx context.Context, remote LFSRemote, ptr LFSPointer) error {
+	if g.blobs == nil {
+		return errLFSStoreUnavailable
+	}
+	object, err := g.lfsBatch(ctx, remote, "upload", ptr)
+	if err != nil {
+		return err
+	}
+	upload, ok := object.Actions["upload"]
+	if !ok {
+		return nil
+	}
+
+	reader, _, err := g.blobs.Get(ctx, storage.BlobKey(ptr.OID))
+	if err != nil {
+		return fmt.Errorf("lfs object %s missing from blob store: %w", ptr.OID, err)
+	}
+	defer reader.Close()
+	req, err := lfsRequest(ctx, http.MethodPut, upload, reader)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = ptr.Size
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("lfs upload of %s returned %d", ptr.OID, resp.StatusCode)
+	}
+
+	if verify, ok := object.Actions["verify"]; ok {
+		body, _ := json.Marshal(ptr)
+		req, err := lfsRequest(ctx, http.MethodPost, verify, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", lfsMediaType)
+		req.Header.Set("Content-Type", lfsMediaType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("lfs verify of %s returned %d", ptr.OID, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// resolveLFSContent turns fetched blob content that is an LFS pointer into
+// a stored object. It returns nil when the content is not a pointer or the
+// object could not be fetched, in which case the pointer text is kept.
+func (g *GitService) resolveLFSContent(ctx context.Context, remote LFSRemote, filePath, content string) *LFSPointer {
+	ptr, ok := ParseLFSPointer(content)
+	if !ok {
+		return nil
+	}
+	if err := g.FetchLFSObject(ctx, remote, ptr); err != nil {
+		log.Printf("git: keeping lfs pointer for %s: %v", filePath, err)
+		return nil
+	}
+	return &ptr
+}
+
+// SetImportedContent fills a file being imported from a repository. LFS
+// pointers are swapped for the objects they reference when the blob store
+// and LFS server allow it.
+func (g *GitService) SetImportedContent(ctx context.Context, remote LFSRemote, file *models.File, content string) {
+	setGitContent(file, content, g.resolveLFSContent(ctx, remote, file.Path, content))
+}
+
+// projectLFSAttributes reads the project's root .gitattributes
+func (g *GitService) projectLFSAttributes(ctx context.Context, projectID uint) LFSAttributes {
+	var file models.File
+	if err := g.db.WithContext(ctx).Where("project_id = ? AND path IN ?", projectID, []string{".gitattributes", "/.gitattributes"}).
+		First(&file).Error; err != nil {
+		return LFSAttributes{}
+	}
+	return ParseLFSAttributes(file.Content)
+}
+
+// lfsPointerFor returns the pointer a binary file is committed as, if LFS
+// tracks its path.
+func lfsPointerFor(file *models.File, attrs LFSAttributes) (LFSPointer, bool) {
+	if !file.IsBinary || !storage.ValidBlobHash(file.Hash) || !attrs.Tracks(file.Path) {
+		return LFSPointer{}, false
+	}
+	return LFSPointer{OID: file.Hash, Size: file.Size}, true
+}
+
+// gitBlobContent returns the bytes to commit for a project file. Paths LFS
+// tracks are uploaded to the LFS server and committed as pointers; other
+// binary files are read back from the blob store.
+func (g *GitService) gitBlobContent(ctx context.Context, remote LFSRemote, file *models.File, attrs LFSAttributes) ([]byte, error) {
+	if attrs.Tracks(file.Path) && g.blobs != nil {
+		ptr, ok := lfsPointerFor(file, attrs)
+		if !ok {
+			hash, size, err := storage.PutBlob(ctx, g.blobs, strings.NewReader(file.Content), maxLFSObjectSize, file.MimeType)
+			if err != nil {
+				return nil, err
+			}
+			ptr = LFSPointer{OID: hash, Size: size}
+		}
+		if err := g.PushLFSObject(ctx, remote, ptr); err != nil {
+			return nil, fmt.Errorf("failed to upload %s to git lfs: %w", file.Path, err)
+		}
+		return []byte(ptr.String()), nil
+	}
+	if file.IsBinary {
+		if g.blobs == nil {
+			return nil, errLFSStoreUnavailable
+		}
+		return storage.ReadBlob(ctx, g.blobs, file.Hash)
+	}
+	return []byte(file.Content), nil
+}
+
+// setGitContent stores pulled content on a file: LFS objects become binary
+// files backed by the blob store, anything else is kept as text.
+func setGitContent(file *models.File, content string, lfs *LFSPointer) {
+	if lfs != nil {
+		file.Content, file.Size, file.Hash, file.IsBinary = "", lfs.Size, lfs.OID, true
+		return
+	}
+	file.Content, file.Size, file.Hash, file.IsBinary = content, int64(len(content)), "", false
+}
+
+// gitContentColumns is setGitContent for column updates
+func gitContentColumns(content string, lfs *LFSPointer) map[string]interface{} {
+	if lfs != nil {
+		return map[string]interface{}{"content": "", "size": lfs.Size, "hash": lfs.OID, "is_binary": true}
+	}
+	return map[string]interface{}{"content": content, "size": int64(len(content)), "hash": "", "is_binary": false}
+}
@@ -0,0 +1,161 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLFSPointerRoundTrip(t *testing.T) {
+	sum := sha256.Sum256([]byte("png bytes"))
+	ptr := LFSPointer{OID: hex.EncodeToString(sum[:]), Size: 9}
+
+	parsed, ok := ParseLFSPointer(ptr.String())
+	require.True(t, ok)
+	require.Equal(t, ptr, parsed)
+
+	_, ok = ParseLFSPointer("version https://git-lfs.github.com/spec/v1\noid sha256:nothex\nsize 9\n")
+	require.False(t, ok)
+	_, ok = ParseLFSPointer("just a text file\n")
+	require.False(t, ok)
+}
+
+func TestLFSAttributesTracks(t *testing.T) {
+	attrs := ParseLFSAttributes(`# binaries
+*.png filter=lfs diff=lfs merge=lfs -text
+assets/video/** filter=lfs diff=lfs merge=lfs -text
+/models/*.bin filter=lfs -text
+*.psd filter=lfs
+*.psd -filter
+*.md text
+`)
+
+	require.Equal(t, []string{"*.png", "assets/video/**", "/models/*.bin"}, attrs.Patterns())
+	require.True(t, attrs.Tracks("logo.png"))
+	require.True(t, attrs.Tracks("public/img/logo.png"))
+	require.True(t, attrs.Tracks("assets/video/intro/clip.mp4"))
+	require.True(t, attrs.Tracks("models/weights.bin"))
+	require.False(t, attrs.Tracks("src/models/weights.bin"))
+	require.False(t, attrs.Tracks("design.psd"))
+	require.False(t, attrs.Tracks("README.md"))
+}
+
+// fakeLFSServer serves the batch API for acme/site from an in-memory object
+// map; uploads land in the same map.
+func fakeLFSServer(t *testing.T, objects map[string][]byte) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/acme/site.git/info/lfs/objects/batch":
+			user, pass, _ := r.BasicAuth()
+			require.Equal(t, "x-access-token", user)
+			require.Equal(t, "token", pass)
+			var batch struct {
+				Operation string       `json:"operation"`
+				Objects   []LFSPointer `json:"objects"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+			var out []map[string]interface{}
+			for _, object := range batch.Objects {
+				href := map[string]string{"href": server.URL + "/objects/" + object.OID}
+				actions := map[string]interface{}{}
+				if _, stored := objects[object.OID]; batch.Operation == "download" && stored {
+					actions["download"] = href
+				} else if batch.Operation == "upload" && !stored {
+					actions["upload"] = href
+				}
+				out = append(out, map[string]interface{}{"oid": object.OID, "size": object.Size, "actions": actions})
+			}
+			w.Header().Set("Content-Type", lfsMediaType)
+			json.NewEncoder(w).Encode(map[string]interface{}{"objects": out})
+		case strings.HasPrefix(r.URL.Path, "/objects/") && r.Method == http.MethodGet:
+			w.Write(objects[strings.TrimPrefix(r.URL.Path, "/objects/")])
+		case strings.HasPrefix(r.URL.Path, "/objects/") && r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[strings.TrimPrefix(r.URL.Path, "/objects/")] = body
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSyncFromRemoteFetchesLFSObjects(t *testing.T) {
+	logo := []byte("\x89PNG fake image bytes")
+	sum := sha256.Sum256(logo)
+	ptr := LFSPointer{OID: hex.EncodeToString(sum[:]), Size: int64(len(logo))}
+
+	service, db, projectID := newSyncTestFixture(t, map[string]string{
+		".gitattributes": "*.png filter=lfs diff=lfs merge=lfs -text\n",
+		"img/logo.png":   ptr.String(),
+	})
+	blobs, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	service.SetBlobStore(blobs)
+	service.lfsBase = fakeLFSServer(t, map[string][]byte{ptr.OID: logo}).URL
+
+	result, err := service.SyncFromRemote(context.Background(), projectID, "token", "abc123")
+	require.NoError(t, err)
+	require.Equal(t, []string{".gitattributes", "img/logo.png"}, result.Updated)
+
+	var file models.File
+	require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "img/logo.png").First(&file).Error)
+	require.True(t, file.IsBinary)
+	require.Empty(t, file.Content)
+	require.Equal(t, ptr.OID, file.Hash)
+	require.Equal(t, ptr.Size, file.Size)
+
+	stored, err := storage.ReadBlob(context.Background(), blobs, ptr.OID)
+	require.NoError(t, err)
+	require.Equal(t, logo, stored)
+
+	// Unchanged on the next sync: the binary file is compared as its pointer.
+	result, err = service.SyncFromRemote(context.Background(), projectID, "token", "abc123")
+	require.NoError(t, err)
+	require.Empty(t, result.Updated)
+	require.Empty(t, result.Conflicts)
+}
+
+func TestGitBlobContentUploadsTrackedFiles(t *testing.T) {
+	service, db, projectID := newSyncTestFixture(t, nil)
+	blobs, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	service.SetBlobStore(blobs)
+	objects := map[string][]byte{}
+	service.lfsBase = fakeLFSServer(t, objects).URL
+
+	require.NoError(t, db.Create(&models.File{ProjectID: projectID, Path: ".gitattributes", Name: ".gitattributes", Type: "file", Content: "*.bin filter=lfs -text\n"}).Error)
+	attrs := service.projectLFSAttributes(context.Background(), projectID)
+	remote := service.lfsRemote("acme", "site", "token")
+
+	hash, size, err := storage.PutBlob(context.Background(), blobs, strings.NewReader("weights"), 1024, "application/octet-stream")
+	require.NoError(t, err)
+	tracked := &models.File{Path: "model.bin", IsBinary: true, Hash: hash, Size: size}
+	content, err := service.gitBlobContent(context.Background(), remote, tracked, attrs)
+	require.NoError(t, err)
+	require.Equal(t, LFSPointer{OID: hash, Size: size}.String(), string(content))
+	require.Equal(t, []byte("weights"), objects[hash])
+
+	// Untracked binaries are committed as their bytes.
+	untracked := &models.File{Path: "data.dat", IsBinary: true, Hash: hash, Size: size}
+	content, err = service.gitBlobContent(context.Background(), remote, untracked, attrs)
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(content))
+}
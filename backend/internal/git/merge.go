@@ -0,0 +1,161 @@
+package git
+
+import "strings"
+
+// maxMergeCells caps the line-diff table (base lines x side lines). Larger
+// files are treated as a single whole-file conflict.
+const maxMergeCells = 4_000_000
+
+// ConflictHunk is one region both sides changed differently. Line numbers
+// are 1-based; BaseLines is 0 for an insertion.
+type ConflictHunk struct {
+	Index       int    `json:"index"`
+	BaseStart   int    `json:"base_start"`
+	BaseLines   int    `json:"base_lines"`
+	MergedStart int    `json:"merged_start"` // line of the <<<<<<< marker in Merged
+	Base        string `json:"base"`
+	Ours        string `json:"ours"`
+	Theirs      string `json:"theirs"`
+}
+
+// MergeResult is the outcome of a three-way merge
+type MergeResult struct {
+	Clean  bool           `json:"clean"`
+	Merged string         `json:"merged"` // conflict regions carry git-style markers
+	Hunks  []ConflictHunk `json:"hunks"`
+}
+
+// Merge3 merges ours and theirs against their common base line by line,
+// the way diff3 does: regions changed on only one side are taken from that
+// side, regions changed identically are taken once, and everything else is
+// reported as a conflict hunk.
+func Merge3(base, ours, theirs string) MergeResult {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	result := MergeResult{Clean: true, Hunks: []ConflictHunk{}}
+	var merged []string
+
+	emit := func(bi int, bc, oc, tc []string) {
+		switch {
+		case len(bc) == 0 && len(oc) == 0 && len(tc) == 0:
+		case equalLines(oc, bc):
+			merged = append(merged, tc...)
+		case equalLines(tc, bc), equalLines(oc, tc):
+			merged = append(merged, oc...)
+		default:
+			result.Clean = false
+			result.Hunks = append(result.Hunks, ConflictHunk{
+				Index:       len(result.Hunks),
+				BaseStart:   bi + 1,
+				BaseLines:   len(bc),
+				MergedStart: len(merged) + 1,
+				Base:        strings.Join(bc, ""),
+				Ours:        strings.Join(oc, ""),
+				Theirs:      strings.Join(tc, ""),
+			})
+			merged = append(merged, "<<<<<<< ours\n")
+			merged = append(merged, withTrailingNewline(oc)...)
+			merged = append(merged, "=======\n")
+			merged = append(merged, withTrailingNewline(tc)...)
+			merged = append(merged, ">>>>>>> theirs\n")
+		}
+	}
+
+	if len(b)*max(len(o), len(t)) > maxMergeCells {
+		emit(0, b, o, t)
+		result.Merged = strings.Join(merged, "")
+		return result
+	}
+
+	mo, mt := matchLines(b, o), matchLines(b, t)
+	i, j, k := 0, 0, 0
+	for {
+		// Next base line kept unchanged by both sides
+		next := -1
+		for x := i; x < len(b); x++ {
+			if mo[x] >= 0 && mt[x] >= 0 {
+				next = x
+				break
+			}
+		}
+		if next < 0 {
+			emit(i, b[i:], o[j:], t[k:])
+			break
+		}
+		emit(i, b[i:next], o[j:mo[next]], t[k:mt[next]])
+		merged = append(merged, b[next])
+		i, j, k = next+1, mo[next]+1, mt[next]+1
+	}
+
+	result.Merged = strings.Join(merged, "")
+	return result
+}
+
+// matchLines returns, for each line of a, the index of the line of b it is
+// paired with in a longest common subsequence, or -1.
+func matchLines(a, b []string) []int {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for x := range lcs {
+		lcs[x] = make([]int32, m+1)
+	}
+	for x := n - 1; x >= 0; x-- {
+		for y := m - 1; y >= 0; y-- {
+			if a[x] == b[y] {
+				lcs[x][y] = lcs[x+1][y+1] + 1
+			} else {
+				lcs[x][y] = max(lcs[x+1][y], lcs[x][y+1])
+			}
+		}
+	}
+
+	matches := make([]int, n)
+	x, y := 0, 0
+	for x < n {
+		switch {
+		case y < m && a[x] == b[y]:
+			matches[x] = y
+			x++
+			y++
+		case y < m && lcs[x][y+1] >= lcs[x+1][y]:
+			y++
+		default:
+			matches[x] = -1
+			x++
+		}
+	}
+	return matches
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// withTrailingNewline keeps conflict markers on their own line when a side
+// ends without a newline.
+func withTrailingNewline(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	out := append([]string(nil), lines...)
+	out[len(out)-1] += "\n"
+	return out
+}
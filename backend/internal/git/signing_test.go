@@ -0,0 +1,120 @@
+package git
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHSignerWritesVerifiableSSHSIG(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+
+	signer, err := ParseSigningKey(string(pem.EncodeToMemory(block)), "")
+	require.NoError(t, err)
+	require.Equal(t, SigningFormatSSH, signer.Format())
+	require.True(t, strings.HasPrefix(signer.PublicKey(), "ssh-ed25519 "))
+
+	payload := []byte("tree abc\n\nmessage")
+	armored, err := signer.Sign(payload)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----\n"))
+
+	body := strings.TrimSuffix(strings.TrimPrefix(armored, "-----BEGIN SSH SIGNATURE-----\n"), "-----END SSH SIGNATURE-----\n")
+	blob, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\n", ""))
+	require.NoError(t, err)
+	require.Equal(t, "SSHSIG", string(blob[:6]))
+
+	var parsed struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	require.NoError(t, ssh.Unmarshal(blob[6:], &parsed))
+	require.Equal(t, "git", parsed.Namespace)
+	require.Equal(t, "sha512", parsed.HashAlgorithm)
+
+	pub, err := ssh.ParsePublicKey(parsed.PublicKey)
+	require.NoError(t, err)
+	var sig ssh.Signature
+	require.NoError(t, ssh.Unmarshal(parsed.Signature, &sig))
+
+	signed := func(message []byte) []byte {
+		digest := sha512.Sum512(message)
+		return append([]byte("SSHSIG"), ssh.Marshal(struct {
+			Namespace     string
+			Reserved      string
+			HashAlgorithm string
+			Hash          []byte
+		}{"git", "", "sha512", digest[:]})...)
+	}
+	require.NoError(t, pub.Verify(signed(payload), &sig))
+	require.Error(t, pub.Verify(signed([]byte("tampered")), &sig))
+}
+
+func TestGPGSignerProducesDetachedSignature(t *testing.T) {
+	entity, err := openpgp.NewEntity("Dev Eloper", "", "dev@example.com", nil)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	signer, err := ParseSigningKey(buf.String(), "")
+	require.NoError(t, err)
+	require.Equal(t, SigningFormatGPG, signer.Format())
+	name, email := signer.Identity()
+	require.Equal(t, "Dev Eloper", name)
+	require.Equal(t, "dev@example.com", email)
+
+	payload := []byte("tree abc\n\nmessage")
+	armored, err := signer.Sign(payload)
+	require.NoError(t, err)
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(payload), strings.NewReader(armored))
+	require.NoError(t, err)
+
+	_, err = ParseSigningKey("not a key", "")
+	require.ErrorIs(t, err, ErrUnsupportedSigner)
+}
+
+type recordingSigner struct{ payload string }
+
+func (s *recordingSigner) Format() string             { return SigningFormatSSH }
+func (s *recordingSigner) Fingerprint() string        { return "" }
+func (s *recordingSigner) PublicKey() string          { return "" }
+func (s *recordingSigner) Identity() (string, string) { return "", "" }
+func (s *recordingSigner) Sign(payload []byte) (string, error) {
+	s.payload = string(payload)
+	return "SIG", nil
+}
+
+func TestCommitAuthorSignsGitCommitObject(t *testing.T) {
+	signer := &recordingSigner{}
+	author := &commitAuthor{signer: signer, name: "Dev", email: "dev@example.com"}
+	commitData := map[string]interface{}{}
+	when := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, author.sign(commitData, "tree1", []string{"parent1"}, "Update files", when))
+	require.Equal(t, "tree tree1\nparent parent1\n"+
+		"author Dev <dev@example.com> 1772366400 +0000\n"+
+		"committer Dev <dev@example.com> 1772366400 +0000\n\nUpdate files", signer.payload)
+	require.Equal(t, "SIG", commitData["signature"])
+	require.Equal(t, map[string]string{"name": "Dev", "email": "dev@example.com", "date": "2026-03-01T12:00:00Z"}, commitData["committer"])
+}
@@ -0,0 +1,663 @@
+package git
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const githubAPIBase = "https://api.github.com"
+
+// Sync states reported on Repository.SyncStatus
+const (
+	SyncStatusIdle     = "idle"
+	SyncStatusSyncing  = "syncing"
+	SyncStatusSynced   = "synced"
+	SyncStatusConflict = "conflict"
+	SyncStatusError    = "error"
+)
+
+// Conflict kinds recorded on SyncConflict.Kind
+const (
+	ConflictModified        = "modified"         // both sides changed the file
+	ConflictDeletedRemotely = "deleted_remotely" // edited locally, deleted upstream
+	ConflictDeletedLocally  = "deleted_locally"  // deleted locally, edited upstream
+)
+
+// autoSyncTimeout bounds a single webhook-triggered sync
+const autoSyncTimeout = 5 * time.Minute
+
+// SyncedFile records the blob a project file had when it last matched the
+// remote. It is the merge base used to tell local edits from upstream ones.
+type SyncedFile struct {
+	ID        uint   `gorm:"primaryKey"`
+	ProjectID uint   `gorm:"uniqueIndex:idx_git_synced_files_project_path"`
+	Path      string `gorm:"uniqueIndex:idx_git_synced_files_project_path"`
+	BlobSHA   string `gorm:"size:40"`
+	UpdatedAt time.Time
+}
+
+// TableName keeps sync bases alongside the other git tables
+func (SyncedFile) TableName() string { return "git_synced_files" }
+
+// SyncConflict is a file the last sync could not apply because it changed
+// both locally and upstream. The local copy is left untouched.
+type SyncConflict struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProjectID uint      `json:"project_id" gorm:"index"`
+	Path      string    `json:"path"`
+	Kind      string    `json:"kind"`
+	BaseSHA   string    `json:"base_sha,omitempty"`
+	LocalSHA  string    `json:"local_sha,omitempty"`
+	RemoteSHA string    `json:"remote_sha,omitempty"`
+	CommitSHA string    `json:"commit_sha"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Set once the conflict is resolved; resolved rows are kept until the
+	// merge is committed.
+	Resolution string     `json:"resolution,omitempty"` // ours, theirs, manual
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// TableName keeps sync conflicts alongside the other git tables
+func (SyncConflict) TableName() string { return "git_sync_conflicts" }
+
+// SyncResult summarises one remote-to-project sync
+type SyncResult struct {
+	CommitSHA string          `json:"commit_sha"`
+	Updated   []string        `json:"updated"`
+	Merged    []string        `json:"merged"` // changed on both sides, merged cleanly
+	Deleted   []string        `json:"deleted"`
+	Conflicts []*SyncConflict `json:"conflicts"`
+}
+
+// BlobSHA returns the git blob object id for content, matching the SHAs
+// GitHub reports in trees.
+func BlobSHA(content string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	io.WriteString(h, content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a GitHub X-Hub-Signature-256 header
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+type syncOp int
+
+const (
+	syncWrite    syncOp = iota // take the remote content
+	syncRebase                 // contents already agree; only move the base
+	syncMerge                  // both sides changed different lines; take the merge
+	syncDelete                 // remove the local file
+	syncForget                 // both sides deleted; drop the base
+	syncConflict               // leave the local copy and record a conflict
+)
+
+type syncAction struct {
+	Path      string
+	Op        syncOp
+	Kind      string
+	BaseSHA   string
+	LocalSHA  string
+	RemoteSHA string
+	Content   string // merged content for syncMerge
+}
+
+// planSync three-way compares local, base and remote blob SHAs by path.
+// Paths that only exist locally are never touched.
+func planSync(local, base, remote map[string]string) []syncAction {
+	var actions []syncAction
+	for path, remoteSHA := range remote {
+		baseSHA, hasBase := base[path]
+		localSHA, hasLocal := local[path]
+		action := syncAction{Path: path, BaseSHA: baseSHA, LocalSHA: localSHA, RemoteSHA: remoteSHA}
+		switch {
+		case hasBase && remoteSHA == baseSHA:
+			continue
+		case hasLocal && localSHA == remoteSHA:
+			action.Op = syncRebase
+		case !hasLocal && !hasBase:
+			action.Op = syncWrite
+		case !hasLocal:
+			action.Op, action.Kind = syncConflict, ConflictDeletedLocally
+		case hasBase && localSHA == baseSHA:
+			action.Op = syncWrite
+		default:
+			action.Op, action.Kind = syncConflict, ConflictModified
+		}
+		actions = append(actions, action)
+	}
+	for path, baseSHA := range base {
+		if _, ok := remote[path]; ok {
+			continue
+		}
+		localSHA, hasLocal := local[path]
+		action := syncAction{Path: path, BaseSHA: baseSHA, LocalSHA: localSHA}
+		switch {
+		case !hasLocal:
+			action.Op = syncForget
+		case localSHA == baseSHA:
+			action.Op = syncDelete
+		default:
+			action.Op, action.Kind = syncConflict, ConflictDeletedRemotely
+		}
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Path < actions[j].Path })
+	return actions
+}
+
+// SyncFromRemote brings upstream changes on the connected branch into the
+// project. Files edited only upstream are fast-forwarded, files edited on
+// both sides are recorded as conflicts and left as they are locally.
+// An empty commitSHA syncs the current branch head.
+func (g *GitService) SyncFromRemote(ctx context.Context, projectID uint, token, commitSHA string) (*SyncResult, error) {
+	repo, err := g.GetRepository(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Provider != "github" {
+		return nil, fmt.Errorf("provider not supported: %s", repo.Provider)
+	}
+
+	g.db.WithContext(ctx).Model(repo).Updates(map[string]interface{}{
+		"sync_status": SyncStatusSyncing,
+		"sync_error":  "",
+	})
+
+	result, err := g.syncFromGitHub(ctx, repo, token, commitSHA)
+	if err != nil {
+		g.db.WithContext(context.Background()).Model(repo).Updates(map[string]interface{}{
+			"sync_status": SyncStatusError,
+			"sync_error":  err.Error(),
+		})
+		return nil, err
+	}
+	return result, nil
+}
+
+func (g *GitService) syncFromGitHub(ctx context.Context, repo *Repository, token, commitSHA string) (*SyncResult, error) {
+	if commitSHA == "" {
+		head, err := g.fetchGitHubHead(ctx, repo, token)
+		if err != nil {
+			return nil, err
+		}
+		commitSHA = head
+	}
+
+	entries, err := g.fetchGitHubTree(ctx, repo, token, commitSHA)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []models.File
+	if err := g.db.WithContext(ctx).Where("project_id = ?", repo.ProjectID).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	attrs := g.projectLFSAttributes(ctx, repo.ProjectID)
+	lfsRemote := g.lfsRemote(repo.RepoOwner, repo.RepoName, token)
+	local := make(map[string]string, len(files))
+	byPath := make(map[string]models.File, len(files))
+	binary := make(map[string]bool)
+	for _, file := range files {
+		if file.Type == "directory" {
+			continue
+		}
+		// LFS-tracked binaries sync as their pointer; other binary bytes live
+		// only in the blob store and are left out of text sync.
+		if ptr, ok := lfsPointerFor(&file, attrs); ok {
+			local[file.Path] = BlobSHA(ptr.String())
+			byPath[file.Path] = file
+			continue
+		}
+		if file.IsBinary {
+			binary[file.Path] = true
+			continue
+		}
+		local[file.Path] = BlobSHA(file.Content)
+		byPath[file.Path] = file
+	}
+
+	var bases []SyncedFile
+	if err := g.db.WithContext(ctx).Where("project_id = ?", repo.ProjectID).Find(&bases).Error; err != nil {
+		return nil, err
+	}
+	base := make(map[string]string, len(bases))
+	for _, b := range bases {
+		base[b.Path] = b.BlobSHA
+	}
+
+	remote := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if entry.Type == "blob" && !binary[entry.Path] {
+			remote[entry.Path] = entry.SHA
+		}
+	}
+
+	actions := planSync(local, base, remote)
+
+	// Fetch upstream content before opening the transaction. Files edited on
+	// both sides are merged line by line and only conflict if the edits
+	// overlap.
+	contents := make(map[string]string)
+	lfsObjects := make(map[string]*LFSPointer)
+	for i, action := range actions {
+		switch {
+		case action.Op == syncWrite:
+			content, err := g.fetchGitHubBlob(ctx, repo, token, action.RemoteSHA)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch blob %s: %w", action.Path, err)
+			}
+			contents[action.Path] = content
+			lfsObjects[action.Path] = g.resolveLFSContent(ctx, lfsRemote, action.Path, content)
+		case action.Op == syncConflict && action.Kind == ConflictModified && action.BaseSHA != "" && !byPath[action.Path].IsBinary:
+			baseContent, err := g.fetchGitHubBlob(ctx, repo, token, action.BaseSHA)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch blob %s: %w", action.Path, err)
+			}
+			remoteContent, err := g.fetchGitHubBlob(ctx, repo, token, action.RemoteSHA)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch blob %s: %w", action.Path, err)
+			}
+			if _, isPointer := ParseLFSPointer(remoteContent); isPointer {
+				continue
+			}
+			if merge := Merge3(baseContent, byPath[action.Path].Content, remoteContent); merge.Clean {
+				actions[i].Op = syncMerge
+				actions[i].Content = merge.Merged
+			}
+		}
+	}
+
+	result := &SyncResult{CommitSHA: commitSHA, Updated: []string{}, Merged: []string{}, Deleted: []string{}, Conflicts: []*SyncConflict{}}
+	now := time.Now()
+	err = g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, action := range actions {
+			switch action.Op {
+			case syncWrite:
+				if err := g.writeSyncedFile(tx, repo.ProjectID, action.Path, contents[action.Path], lfsObjects[action.Path], byPath); err != nil {
+					return err
+				}
+				result.Updated = append(result.Updated, action.Path)
+				if err := setSyncBase(tx, repo.ProjectID, action.Path, action.RemoteSHA); err != nil {
+					return err
+				}
+			case syncMerge:
+				if err := g.writeSyncedFile(tx, repo.ProjectID, action.Path, action.Content, nil, byPath); err != nil {
+					return err
+				}
+				result.Merged = append(result.Merged, action.Path)
+				if err := setSyncBase(tx, repo.ProjectID, action.Path, action.RemoteSHA); err != nil {
+					return err
+				}
+			case syncRebase:
+				if err := setSyncBase(tx, repo.ProjectID, action.Path, action.RemoteSHA); err != nil {
+					return err
+				}
+			case syncDelete:
+				if err := tx.Where("project_id = ? AND path = ?", repo.ProjectID, action.Path).Delete(&models.File{}).Error; err != nil {
+					return err
+				}
+				result.Deleted = append(result.Deleted, action.Path)
+				fallthrough
+			case syncForget:
+				if err := tx.Where("project_id = ? AND path = ?", repo.ProjectID, action.Path).Delete(&SyncedFile{}).Error; err != nil {
+					return err
+				}
+			case syncConflict:
+				result.Conflicts = append(result.Conflicts, &SyncConflict{
+					ProjectID: repo.ProjectID,
+					Path:      action.Path,
+					Kind:      action.Kind,
+					BaseSHA:   action.BaseSHA,
+					LocalSHA:  action.LocalSHA,
+					RemoteSHA: action.RemoteSHA,
+					CommitSHA: commitSHA,
+					CreatedAt: now,
+				})
+			}
+		}
+
+		// Conflicts are recomputed from the bases on every sync, so the new
+		// set replaces the old unresolved one.
+		if err := tx.Where("project_id = ? AND resolved_at IS NULL", repo.ProjectID).Delete(&SyncConflict{}).Error; err != nil {
+			return err
+		}
+		if len(result.Conflicts) > 0 {
+			if err := tx.Create(&result.Conflicts).Error; err != nil {
+				return err
+			}
+		}
+
+		status := SyncStatusSynced
+		if len(result.Conflicts) > 0 {
+			status = SyncStatusConflict
+		}
+		repo.SyncStatus = status
+		repo.SyncError = ""
+		repo.LastSyncedSHA = commitSHA
+		repo.LastSync = now
+		return tx.Model(repo).Updates(map[string]interface{}{
+			"sync_status":     status,
+			"sync_error":      "",
+			"last_synced_sha": commitSHA,
+			"last_sync":       now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeSyncedFile stores content pulled from the remote; a non-nil lfs
+// pointer means the file's bytes are the fetched LFS object.
+func (g *GitService) writeSyncedFile(tx *gorm.DB, projectID uint, path, content string, lfs *LFSPointer, existing map[string]models.File) error {
+	if file, ok := existing[path]; ok {
+		updates := gitContentColumns(content, lfs)
+		updates["version"] = gorm.Expr("version + 1")
+		updates["updated_at"] = time.Now()
+		return tx.Model(&models.File{}).Where("id = ?", file.ID).Updates(updates).Error
+	}
+	file := models.File{
+		ProjectID: projectID,
+		Name:      g.getFileName(path),
+		Path:      path,
+		Type:      "file",
+		MimeType:  g.getMimeType(path),
+		Version:   1,
+	}
+	setGitContent(&file, content, lfs)
+	return tx.Create(&file).Error
+}
+
+func setSyncBase(tx *gorm.DB, projectID uint, path, sha string) error {
+	res := tx.Model(&SyncedFile{}).Where("project_id = ? AND path = ?", projectID, path).
+		Updates(map[string]interface{}{"blob_sha": sha, "updated_at": time.Now()})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	return tx.Create(&SyncedFile{ProjectID: projectID, Path: path, BlobSHA: sha}).Error
+}
+
+// replaceSyncBases resets the merge bases after a full pull
+func replaceSyncBases(tx *gorm.DB, projectID uint, shas map[string]string) error {
+	if err := tx.Where("project_id = ?", projectID).Delete(&SyncedFile{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&SyncConflict{}).Error; err != nil {
+		return err
+	}
+	rows := make([]SyncedFile, 0, len(shas))
+	for path, sha := range shas {
+		rows = append(rows, SyncedFile{ProjectID: projectID, Path: path, BlobSHA: sha})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(rows, 200).Error
+}
+
+// GetSyncConflicts lists the unresolved conflicts left by the last sync
+func (g *GitService) GetSyncConflicts(ctx context.Context, projectID uint) ([]*SyncConflict, error) {
+	var conflicts []*SyncConflict
+	err := g.db.WithContext(ctx).Where("project_id = ? AND resolved_at IS NULL", projectID).Order("path").Find(&conflicts).Error
+	return conflicts, err
+}
+
+// ClearSyncState drops merge bases and conflicts, e.g. on disconnect
+func (g *GitService) ClearSyncState(ctx context.Context, projectID uint) error {
+	db := g.db.WithContext(ctx)
+	if err := db.Where("project_id = ?", projectID).Delete(&SyncedFile{}).Error; err != nil {
+		return err
+	}
+	return db.Where("project_id = ?", projectID).Delete(&SyncConflict{}).Error
+}
+
+// QueueSync runs SyncFromRemote in the background. Pushes that arrive while
+// a sync is running collapse into a single follow-up sync of the newest commit.
+func (g *GitService) QueueSync(projectID uint, token, commitSHA string) {
+	g.mu.Lock()
+	if g.syncing == nil {
+		g.syncing = make(map[uint]*pendingSync)
+	}
+	if _, running := g.syncing[projectID]; running {
+		g.syncing[projectID] = &pendingSync{token: token, commitSHA: commitSHA}
+		g.mu.Unlock()
+		return
+	}
+	g.syncing[projectID] = nil
+	g.mu.Unlock()
+
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), autoSyncTimeout)
+			if _, err := g.SyncFromRemote(ctx, projectID, token, commitSHA); err != nil {
+				log.Printf("git: auto-sync of project %d failed: %v", projectID, err)
+			}
+			cancel()
+
+			g.mu.Lock()
+			next := g.syncing[projectID]
+			if next == nil {
+				delete(g.syncing, projectID)
+				g.mu.Unlock()
+				return
+			}
+			g.syncing[projectID] = nil
+			g.mu.Unlock()
+			token, commitSHA = next.token, next.commitSHA
+		}
+	}()
+}
+
+type pendingSync struct {
+	token     string
+	commitSHA string
+}
+
+// EnableAutoSync registers a push webhook on the remote repository so pushes
+// to the connected branch sync into the project automatically.
+func (g *GitService) EnableAutoSync(ctx context.Context, projectID uint, token, callbackURL string) (*Repository, error) {
+	repo, err := g.GetRepository(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Provider != "github" {
+		return nil, fmt.Errorf("provider not supported: %s", repo.Provider)
+	}
+	if repo.AutoSync && repo.WebhookID != 0 {
+		return repo, nil
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, err
+	}
+	secret := hex.EncodeToString(secretBytes)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"name":   "web",
+		"active": true,
+		"events": []string{"push"},
+		"config": map[string]string{
+			"url":          callbackURL,
+			"content_type": "json",
+			"secret":       secret,
+			"insecure_ssl": "0",
+		},
+	})
+	var hook struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.githubJSON(ctx, http.MethodPost, g.githubURL("/repos/%s/%s/hooks", repo.RepoOwner, repo.RepoName), token, payload, &hook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	repo.AutoSync = true
+	repo.WebhookID = hook.ID
+	repo.WebhookSecret = secret
+	if repo.SyncStatus == "" {
+		repo.SyncStatus = SyncStatusIdle
+	}
+	repo.UpdatedAt = time.Now()
+	if err := g.db.WithContext(ctx).Save(repo).Error; err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// DisableAutoSync removes the push webhook. A hook already deleted on the
+// remote is not an error.
+func (g *GitService) DisableAutoSync(ctx context.Context, projectID uint, token string) (*Repository, error) {
+	repo, err := g.GetRepository(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if repo.WebhookID != 0 && repo.Provider == "github" {
+		hookURL := g.githubURL("/repos/%s/%s/hooks/%d", repo.RepoOwner, repo.RepoName, repo.WebhookID)
+		if err := g.githubJSON(ctx, http.MethodDelete, hookURL, token, nil, nil); err != nil && !isGitHubNotFound(err) {
+			return nil, fmt.Errorf("failed to delete webhook: %w", err)
+		}
+	}
+
+	repo.AutoSync = false
+	repo.WebhookID = 0
+	repo.WebhookSecret = ""
+	repo.UpdatedAt = time.Now()
+	if err := g.db.WithContext(ctx).Save(repo).Error; err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+type githubTreeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+type githubStatusError struct {
+	status int
+	body   string
+}
+
+func (e *githubStatusError) Error() string {
+	return fmt.Sprintf("github returned %d: %s", e.status, e.body)
+}
+
+func isGitHubNotFound(err error) bool {
+	statusErr, ok := err.(*githubStatusError)
+	return ok && statusErr.status == http.StatusNotFound
+}
+
+func (g *GitService) githubURL(format string, args ...interface{}) string {
+	base := g.apiBase
+	if base == "" {
+		base = githubAPIBase
+	}
+	return base + fmt.Sprintf(format, args...)
+}
+
+// githubJSON performs a GitHub API call, decoding a JSON response into out
+func (g *GitService) githubJSON(ctx context.Context, method, url, token string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = strings.NewReader(string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &githubStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (g *GitService) fetchGitHubHead(ctx context.Context, repo *Repository, token string) (string, error) {
+	var ref struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := g.githubJSON(ctx, http.MethodGet, g.githubURL("/repos/%s/%s/git/ref/heads/%s", repo.RepoOwner, repo.RepoName, repo.Branch), token, nil, &ref); err != nil {
+		return "", fmt.Errorf("failed to resolve branch %s: %w", repo.Branch, err)
+	}
+	return ref.Object.SHA, nil
+}
+
+// fetchGitHubTree lists every entry reachable from ref (a branch or commit)
+func (g *GitService) fetchGitHubTree(ctx context.Context, repo *Repository, token, ref string) ([]githubTreeEntry, error) {
+	var tree struct {
+		Tree []githubTreeEntry `json:"tree"`
+	}
+	if err := g.githubJSON(ctx, http.MethodGet, g.githubURL("/repos/%s/%s/git/trees/%s?recursive=1", repo.RepoOwner, repo.RepoName, ref), token, nil, &tree); err != nil {
+		return nil, fmt.Errorf("failed to fetch repository tree: %w", err)
+	}
+	return tree.Tree, nil
+}
+
+func (g *GitService) fetchGitHubBlob(ctx context.Context, repo *Repository, token, sha string) (string, error) {
+	var blob struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := g.githubJSON(ctx, http.MethodGet, g.githubURL("/repos/%s/%s/git/blobs/%s", repo.RepoOwner, repo.RepoName, sha), token, nil, &blob); err != nil {
+		return "", err
+	}
+	if blob.Encoding != "base64" {
+		return blob.Content, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(blob.Content, "\n", ""))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode blob: %w", err)
+	}
+	return string(decoded), nil
+}
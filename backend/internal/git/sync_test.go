@@ -0,0 +1,153 @@
+package git
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBlobSHAMatchesGit(t *testing.T) {
+	// git hash-object of "hello\n"
+	require.Equal(t, "ce013625030ba8dba906f756967f9e9ca394464a", BlobSHA("hello\n"))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	require.True(t, VerifyWebhookSignature("s3cret", body, signature))
+	require.False(t, VerifyWebhookSignature("other", body, signature))
+	require.False(t, VerifyWebhookSignature("s3cret", body, "sha1=abc"))
+	require.False(t, VerifyWebhookSignature("", body, signature))
+}
+
+// newSyncTestFixture connects a project to a fake GitHub serving remote
+// (path -> content) at commit abc123. Blobs in extraBlobs are also served,
+// e.g. old merge bases.
+func newSyncTestFixture(t *testing.T, remote map[string]string, extraBlobs ...string) (*GitService, *gorm.DB, uint) {
+	t.Helper()
+
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}, &Repository{}, &SyncedFile{}, &SyncConflict{}))
+
+	user := models.User{Username: "syncer", Email: "syncer@example.com", PasswordHash: "hashed-password"}
+	require.NoError(t, db.Create(&user).Error)
+	project := models.Project{Name: "Synced", Language: "javascript", OwnerID: user.ID}
+	require.NoError(t, db.Create(&project).Error)
+	require.NoError(t, db.Create(&Repository{ProjectID: project.ID, Provider: "github", RepoOwner: "acme", RepoName: "site", Branch: "main", IsConnected: true}).Error)
+
+	blobs := map[string]string{}
+	for _, content := range extraBlobs {
+		blobs[BlobSHA(content)] = content
+	}
+	var tree []githubTreeEntry
+	for path, content := range remote {
+		sha := BlobSHA(content)
+		blobs[sha] = content
+		tree = append(tree, githubTreeEntry{Path: path, Type: "blob", SHA: sha})
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/acme/site/git/trees/abc123":
+			json.NewEncoder(w).Encode(map[string]interface{}{"tree": tree})
+		case strings.HasPrefix(r.URL.Path, "/repos/acme/site/git/blobs/"):
+			content, ok := blobs[strings.TrimPrefix(r.URL.Path, "/repos/acme/site/git/blobs/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{
+				"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+				"encoding": "base64",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	service := NewGitService(db)
+	service.apiBase = server.URL
+	return service, db, project.ID
+}
+
+// seedSynced stores files as they were at the last sync (file and base agree)
+func seedSynced(t *testing.T, db *gorm.DB, projectID uint, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		require.NoError(t, db.Create(&models.File{ProjectID: projectID, Path: path, Name: path, Type: "file", Content: content}).Error)
+		require.NoError(t, db.Create(&SyncedFile{ProjectID: projectID, Path: path, BlobSHA: BlobSHA(content)}).Error)
+	}
+}
+
+func fileContent(t *testing.T, db *gorm.DB, projectID uint, path string) string {
+	t.Helper()
+	var file models.File
+	require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, path).First(&file).Error)
+	return file.Content
+}
+
+func TestSyncFromRemoteFastForwardsAndRecordsConflicts(t *testing.T) {
+	remote := map[string]string{
+		"untouched.txt": "same\n",
+		"upstream.txt":  "v2\n",
+		"both.txt":      "remote edit\n",
+		"added.txt":     "new upstream\n",
+	}
+	service, db, projectID := newSyncTestFixture(t, remote, "v1\n")
+
+	// Last sync left these four files identical on both sides.
+	seedSynced(t, db, projectID, map[string]string{
+		"untouched.txt": "same\n",
+		"upstream.txt":  "v1\n",
+		"both.txt":      "v1\n",
+		"removed.txt":   "gone soon\n",
+	})
+	require.NoError(t, db.Model(&models.File{}).Where("project_id = ? AND path = ?", projectID, "both.txt").Update("content", "local edit\n").Error)
+	require.NoError(t, db.Create(&models.File{ProjectID: projectID, Path: "local-only.txt", Name: "local-only.txt", Type: "file", Content: "mine\n"}).Error)
+
+	result, err := service.SyncFromRemote(context.Background(), projectID, "token", "abc123")
+	require.NoError(t, err)
+	require.Equal(t, []string{"added.txt", "upstream.txt"}, result.Updated)
+	require.Equal(t, []string{"removed.txt"}, result.Deleted)
+	require.Len(t, result.Conflicts, 1)
+	require.Equal(t, "both.txt", result.Conflicts[0].Path)
+	require.Equal(t, ConflictModified, result.Conflicts[0].Kind)
+
+	require.Equal(t, "v2\n", fileContent(t, db, projectID, "upstream.txt"))
+	require.Equal(t, "new upstream\n", fileContent(t, db, projectID, "added.txt"))
+	require.Equal(t, "local edit\n", fileContent(t, db, projectID, "both.txt"))
+	require.Equal(t, "mine\n", fileContent(t, db, projectID, "local-only.txt"))
+
+	var removed int64
+	db.Model(&models.File{}).Where("project_id = ? AND path = ?", projectID, "removed.txt").Count(&removed)
+	require.Zero(t, removed)
+
+	repo, err := service.GetRepository(context.Background(), projectID)
+	require.NoError(t, err)
+	require.Equal(t, SyncStatusConflict, repo.SyncStatus)
+	require.Equal(t, "abc123", repo.LastSyncedSHA)
+
+	// The conflicted file keeps its old base so the next sync still flags it.
+	var bothBase SyncedFile
+	require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "both.txt").First(&bothBase).Error)
+	require.Equal(t, BlobSHA("v1\n"), bothBase.BlobSHA)
+}
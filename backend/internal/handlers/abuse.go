@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/abuse"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AbuseHandler serves execution enforcement status and appeals to users and
+// the abuse review queue to admins
+type AbuseHandler struct {
+	service *abuse.Service
+}
+
+// NewAbuseHandler creates a new AbuseHandler
+func NewAbuseHandler(service *abuse.Service) *AbuseHandler {
+	return &AbuseHandler{service: service}
+}
+
+// GetAbuseStatus returns whether the caller may run code and their open
+// case, if any.
+// GET /abuse/status
+func (h *AbuseHandler) GetAbuseStatus(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	open, err := h.service.OpenCase(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load status"})
+		return
+	}
+	decision := h.service.Admit(c.Request.Context(), userID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"allowed":                decision.Allowed,
+		"code":                   decision.Code,
+		"reason":                 decision.Reason,
+		"max_timeout_seconds":    int(decision.MaxTimeout.Seconds()),
+		"case":                   open,
+		"can_appeal":             open != nil && open.Enforcement != abuse.EnforceNone && open.AppealedAt == nil,
+		"disposable_email_block": decision.Code == abuse.CodeDisposableEmail,
+	}})
+}
+
+// AppealAbuseCase asks for review of the caller's throttled or suspended
+// execution access.
+// POST /abuse/appeal
+func (h *AbuseHandler) AppealAbuseCase(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	var req struct {
+		Message string `json:"message" binding:"required,max=4000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "message is required (max 4000 characters)"})
+		return
+	}
+	appealed, err := h.service.Appeal(c.Request.Context(), userID, req.Message)
+	switch {
+	case errors.Is(err, abuse.ErrNoCase):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	case errors.Is(err, abuse.ErrAlreadyAppealed):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to submit appeal"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": appealed})
+}
+
+// ListAbuseCases returns the review queue, appealed cases first.
+// GET /admin/abuse/cases?status=appealed&limit=50
+func (h *AbuseHandler) ListAbuseCases(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", abuse.CaseReview, abuse.CaseThrottled, abuse.CaseSuspended, abuse.CaseAppealed, abuse.CaseCleared:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "unknown status"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	items, err := h.service.Queue(c.Request.Context(), status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load abuse cases"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"cases": items}})
+}
+
+func abuseCaseID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid case id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetAbuseCase returns a case with the signals behind it.
+// GET /admin/abuse/cases/:id
+func (h *AbuseHandler) GetAbuseCase(c *gin.Context) {
+	id, ok := abuseCaseID(c)
+	if !ok {
+		return
+	}
+	found, signals, err := h.service.CaseDetail(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "case not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load case"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"case": found, "signals": signals}})
+}
+
+// ResolveAbuseCase clears a case or sets its enforcement.
+// POST /admin/abuse/cases/:id/resolve
+func (h *AbuseHandler) ResolveAbuseCase(c *gin.Context) {
+	id, ok := abuseCaseID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note" binding:"max=4000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "action is required"})
+		return
+	}
+	resolved, err := h.service.Resolve(c.Request.Context(), id, c.GetUint("user_id"), req.Action, req.Note)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "case not found"})
+		return
+	case errors.Is(err, abuse.ErrInvalidAction):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	case errors.Is(err, abuse.ErrCaseClosed):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to resolve case"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": resolved})
+}
+
+// RegisterRoutes registers the user abuse endpoints on an authenticated group
+func (h *AbuseHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/abuse/status", h.GetAbuseStatus)
+	rg.POST("/abuse/appeal", h.AppealAbuseCase)
+}
+
+// RegisterAdminRoutes registers the review queue on the admin group
+func (h *AbuseHandler) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/abuse/cases", h.ListAbuseCases)
+	admin.GET("/abuse/cases/:id", h.GetAbuseCase)
+	admin.POST("/abuse/cases/:id/resolve", h.ResolveAbuseCase)
+}
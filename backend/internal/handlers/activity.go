@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/activity"
+	"apex-build/internal/collaboration"
+	"apex-build/internal/pagination"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityHandler serves a project's activity feed: what collaborators
+// edited, built, deployed and commented on, newest first.
+type ActivityHandler struct {
+	service *activity.Service
+	access  collaboration.AccessResolver
+}
+
+// NewActivityHandler creates a new ActivityHandler. Anyone who may join the
+// project's collaboration room may read its feed.
+func NewActivityHandler(service *activity.Service, access collaboration.AccessResolver) *ActivityHandler {
+	return &ActivityHandler{service: service, access: access}
+}
+
+// ListActivity returns a page of a project's activity.
+// GET /projects/:id/activity?kind=build,deployment&limit=50&cursor=...
+func (h *ActivityHandler) ListActivity(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project id"})
+		return
+	}
+	if _, err := h.access(userID, uint(projectID)); err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Project not found"})
+		case errors.Is(err, collaboration.ErrProjectAccessDenied):
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check project access"})
+		}
+		return
+	}
+
+	list, err := pagination.Parse(c, activity.ListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	var kinds []string
+	for _, kind := range strings.Split(c.Query("kind"), ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	events, pageInfo, err := h.service.List(c.Request.Context(), uint(projectID), list, kinds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load activity"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      gin.H{"activities": events},
+		"page_info": pageInfo,
+	})
+}
+
+// RegisterRoutes mounts the activity feed on the protected API group.
+func (h *ActivityHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/activity", h.ListActivity)
+}
+
+// fileActivityEvent describes a file update for the activity feed, or
+// returns nil when nothing visible changed
+func fileActivityEvent(file *models.File, userID uint, username string, content, path, name *string) *activity.Event {
+	event := &activity.Event{
+		ProjectID: file.ProjectID,
+		ActorID:   userID,
+		ActorName: username,
+		Kind:      activity.KindFileEdit,
+		Target:    file.Path,
+	}
+	switch {
+	case path != nil && *path != file.Path:
+		event.Action, event.Target = "moved", *path
+		event.Summary = fmt.Sprintf("moved %s to %s", file.Path, *path)
+		event.Metadata = map[string]any{"from": file.Path}
+	case name != nil && *name != file.Name:
+		event.Action = "renamed"
+		event.Summary = fmt.Sprintf("renamed %s to %s", file.Name, *name)
+		event.Metadata = map[string]any{"from": file.Name}
+	case content != nil && *content != file.Content:
+		event.Action = "edited"
+		event.Summary = "edited " + file.Path
+	default:
+		return nil
+	}
+	return event
+}
+
+// recordActivity adds an event to the project feed. Failures are logged:
+// the feed never fails the request that caused the event.
+func recordActivity(c *gin.Context, service *activity.Service, event *activity.Event) {
+	if service == nil || event == nil {
+		return
+	}
+	if err := service.Record(c.Request.Context(), *event); err != nil {
+		log.Printf("activity: failed to record %s for project %d: %v", event.Kind, event.ProjectID, err)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WarehouseHandler exposes platform-wide analytics trends to admins.
+type WarehouseHandler struct {
+	warehouse *analytics.Warehouse
+}
+
+// NewWarehouseHandler creates a new WarehouseHandler.
+func NewWarehouseHandler(warehouse *analytics.Warehouse) *WarehouseHandler {
+	return &WarehouseHandler{warehouse: warehouse}
+}
+
+// trendRange parses from/to (YYYY-MM-DD, inclusive), defaulting to the last
+// 30 days
+func trendRange(c *gin.Context, maxDays int) (analytics.TrendQuery, bool) {
+	now := time.Now().UTC()
+	q := analytics.TrendQuery{From: now.AddDate(0, 0, -30), To: now}
+	for name, target := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if raw := c.Query(name); raw != "" {
+			parsed, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": name + " must be YYYY-MM-DD"})
+				return q, false
+			}
+			*target = parsed
+		}
+	}
+	if q.To.Before(q.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must not be before from"})
+		return q, false
+	}
+	if q.To.Sub(q.From) > time.Duration(maxDays)*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "date range is limited to " + strconv.Itoa(maxDays) + " days"})
+		return q, false
+	}
+	return q, true
+}
+
+// GetTrends returns daily signups, activation, active users, builds, AI
+// cost and revenue with totals for the range.
+// GET /admin/analytics/trends?from=2026-01-01&to=2026-01-31
+func (h *WarehouseHandler) GetTrends(c *gin.Context) {
+	q, ok := trendRange(c, analytics.MaxTrendDays)
+	if !ok {
+		return
+	}
+	rows, err := h.warehouse.Trends(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load trends"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from":   q.From.Format("2006-01-02"),
+			"to":     q.To.Format("2006-01-02"),
+			"days":   rows,
+			"totals": analytics.SummarizeTrends(rows),
+		},
+	})
+}
+
+// GetPlanTrends returns daily builds, active users, AI cost and revenue per
+// subscription plan.
+// GET /admin/analytics/plans?from=2026-01-01&to=2026-01-31
+func (h *WarehouseHandler) GetPlanTrends(c *gin.Context) {
+	q, ok := trendRange(c, analytics.MaxTrendDays)
+	if !ok {
+		return
+	}
+	rows, err := h.warehouse.PlanTrends(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load plan trends"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from": q.From.Format("2006-01-02"),
+			"to":   q.To.Format("2006-01-02"),
+			"rows": rows,
+		},
+	})
+}
+
+// GetCohorts returns weekly signup cohorts and their retention by week.
+// GET /admin/analytics/cohorts?from=2026-01-01&to=2026-03-31
+func (h *WarehouseHandler) GetCohorts(c *gin.Context) {
+	q, ok := trendRange(c, analytics.MaxTrendDays)
+	if !ok {
+		return
+	}
+	if c.Query("from") == "" {
+		q.From = q.To.AddDate(0, 0, -7*analytics.RetentionWeeks)
+	}
+	rows, err := h.warehouse.Cohorts(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load cohorts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from":    q.From.Format("2006-01-02"),
+			"to":      q.To.Format("2006-01-02"),
+			"cohorts": rows,
+		},
+	})
+}
+
+// ListExports returns recent warehouse export runs.
+// GET /admin/analytics/exports?limit=100
+func (h *WarehouseHandler) ListExports(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	rows, err := h.warehouse.Exports(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load exports"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"sinks":   h.warehouse.Sinks(),
+			"exports": rows,
+		},
+	})
+}
+
+// Rebuild recomputes daily metrics for a range, for example after a
+// backfill, and optionally re-exports it. The work runs in the background.
+// POST /admin/analytics/rebuild
+func (h *WarehouseHandler) Rebuild(c *gin.Context) {
+	var req struct {
+		From   string `json:"from" binding:"required"`
+		To     string `json:"to" binding:"required"`
+		Export bool   `json:"export"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from and to are required"})
+		return
+	}
+	from, errFrom := time.Parse("2006-01-02", req.From)
+	to, errTo := time.Parse("2006-01-02", req.To)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from and to must be YYYY-MM-DD"})
+		return
+	}
+	if to.Before(from) || to.Sub(from) > analytics.MaxQueryDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "range must be ordered and at most one year"})
+		return
+	}
+	if req.Export && len(h.warehouse.Sinks()) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "no export sinks are configured"})
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		days, err := h.warehouse.Rebuild(ctx, from, to)
+		if err != nil {
+			log.Printf("[warehouse] rebuild %s..%s failed after %d days: %v", req.From, req.To, days, err)
+			return
+		}
+		if req.Export {
+			h.warehouse.Export(ctx, from, to)
+		}
+		log.Printf("[warehouse] rebuilt %d days %s..%s export=%t", days, req.From, req.To, req.Export)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from":   req.From,
+			"to":     req.To,
+			"export": req.Export,
+		},
+	})
+}
+
+// RegisterAdminRoutes registers the warehouse endpoints on the admin group.
+func (h *WarehouseHandler) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/analytics/trends", h.GetTrends)
+	admin.GET("/analytics/plans", h.GetPlanTrends)
+	admin.GET("/analytics/cohorts", h.GetCohorts)
+	admin.GET("/analytics/exports", h.ListExports)
+	admin.POST("/analytics/rebuild", h.Rebuild)
+}
@@ -6,6 +6,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 	"unicode/utf8"
 
 	"apex-build/internal/ai"
+	"apex-build/internal/enterprise"
 	"apex-build/internal/middleware"
 	"apex-build/internal/spend"
 	"apex-build/pkg/models"
@@ -208,7 +210,18 @@ func (h *Handler) GenerateAI(c *gin.Context) {
 			errorCode = "INPUT_TOO_LONG"
 		}
 
-		c.JSON(http.StatusInternalServerError, StandardResponse{
+		status := http.StatusInternalServerError
+		if errors.Is(err, ai.ErrNoCompliantProvider) {
+			status = http.StatusUnprocessableEntity
+			errorMsg = "No AI provider is available in the regions your organization allows."
+			errorCode = "AI_REGION_UNAVAILABLE"
+		} else if errors.Is(err, enterprise.ErrAIRegionPolicyConflict) {
+			status = http.StatusForbidden
+			errorMsg = err.Error()
+			errorCode = "AI_REGION_POLICY_CONFLICT"
+		}
+
+		c.JSON(status, StandardResponse{
 			Success: false,
 			Error:   errorMsg,
 			Code:    errorCode,
@@ -458,6 +471,8 @@ func (h *Handler) logAIRequest(userID uint, req *ai.AIRequest, resp *ai.AIRespon
 
 	if resp != nil {
 		aiRequest.Response = resp.Content
+		aiRequest.Model = ai.GetModelUsed(resp, req)
+		aiRequest.Region = ai.ResponseRegion(resp)
 		if resp.Usage != nil {
 			aiRequest.TokensUsed = resp.Usage.TotalTokens
 			aiRequest.Cost = resp.Usage.Cost
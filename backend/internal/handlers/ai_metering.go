@@ -0,0 +1,174 @@
+// Package handlers - Metering for AI calls made outside /ai/generate
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/pricing"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ErrAICreditsExhausted is returned by MeteredAI when the caller cannot cover
+// the estimated cost of a request
+var ErrAICreditsExhausted = errors.New("insufficient credits for this request")
+
+// meteredRouter is the part of *ai.AIRouter that MeteredAI calls
+type meteredRouter interface {
+	Generate(ctx context.Context, req *ai.AIRequest) (*ai.AIResponse, error)
+	GetDefaultProvider(capability ai.AICapability) ai.AIProvider
+}
+
+// MeteredAI wraps the AI router for handlers that call a model directly
+// instead of going through /ai/generate, such as the Solver and merge
+// conflict suggestions. A call runs on the caller's own keys when they have
+// BYOK configured, reserves and settles credits, and is recorded as an
+// AIRequest row and in the usage tracker's AI request count. Routes using it
+// also carry CheckAIQuota and the budget middleware. It satisfies
+// ErrorSolver and ConflictAssistant.
+type MeteredAI struct {
+	db     *gorm.DB
+	router meteredRouter
+	byok   *ai.BYOKManager
+	usage  *usage.Tracker
+}
+
+// NewMeteredAI creates a MeteredAI. byok may be nil, in which case no
+// credits are charged.
+func NewMeteredAI(db *gorm.DB, router *ai.AIRouter, byok *ai.BYOKManager) *MeteredAI {
+	return &MeteredAI{db: db, router: router, byok: byok}
+}
+
+// SetUsageTracker counts each successful call against the AI request quota
+func (m *MeteredAI) SetUsageTracker(tracker *usage.Tracker) {
+	m.usage = tracker
+}
+
+// Generate runs req for the user in req.UserID, who must be the caller
+func (m *MeteredAI) Generate(ctx context.Context, req *ai.AIRequest) (*ai.AIResponse, error) {
+	parsedUserID, err := strconv.ParseUint(req.UserID, 10, 32)
+	if err != nil || parsedUserID == 0 {
+		return nil, fmt.Errorf("AI request %s has no user to charge", req.ID)
+	}
+	userID := uint(parsedUserID)
+	var projectID *uint
+	if parsed, err := strconv.ParseUint(req.ProjectID, 10, 32); err == nil {
+		id := uint(parsed)
+		projectID = &id
+	}
+
+	target := m.router
+	isBYOK := false
+	if m.byok != nil {
+		if userRouter, hasBYOK, err := m.byok.GetRouterForUser(userID); err == nil && userRouter != nil {
+			target = userRouter
+			isBYOK = hasBYOK
+		}
+	}
+
+	// Reserve credits before making the AI call
+	var reservation *ai.CreditReservation
+	if m.byok != nil {
+		provider := req.Provider
+		if provider == "" {
+			provider = target.GetDefaultProvider(req.Capability)
+		}
+		maxTokens := req.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = 4000
+		}
+		estimatedCost := m.byok.EstimateCost(string(provider), req.Model, len(req.Prompt)+len(req.Code), maxTokens, pricing.ModeFast, isBYOK)
+		if estimatedCost > 0 {
+			res, err := m.byok.ReserveCredits(userID, estimatedCost, isBYOK)
+			if err != nil {
+				if strings.Contains(err.Error(), "INSUFFICIENT_CREDITS") {
+					return nil, ErrAICreditsExhausted
+				}
+				return nil, fmt.Errorf("failed to reserve credits: %w", err)
+			}
+			reservation = res
+		}
+	}
+
+	start := time.Now()
+	resp, genErr := target.Generate(ctx, req)
+
+	record := &models.AIRequest{
+		RequestID:  req.ID,
+		UserID:     userID,
+		ProjectID:  projectID,
+		Provider:   string(req.Provider),
+		Capability: string(req.Capability),
+		Prompt:     req.Prompt,
+		Code:       req.Code,
+		Language:   req.Language,
+		Status:     "completed",
+		Duration:   time.Since(start).Milliseconds(),
+	}
+	cost := 0.0
+	if genErr != nil {
+		record.Status = "failed"
+		record.ErrorMsg = genErr.Error()
+	} else {
+		provider := ai.ActualProvider(resp, req.Provider)
+		modelUsed := ai.GetModelUsed(resp, req)
+		record.Provider = string(provider)
+		record.Model = modelUsed
+		record.Region = ai.ResponseRegion(resp)
+		record.Response = resp.Content
+		inputTokens, outputTokens := 0, 0
+		if resp.Usage != nil {
+			inputTokens = resp.Usage.PromptTokens
+			outputTokens = resp.Usage.CompletionTokens
+			record.TokensUsed = resp.Usage.TotalTokens
+			cost = resp.Usage.Cost
+		}
+		if m.byok != nil {
+			cost = m.byok.BilledCost(string(provider), modelUsed, inputTokens, outputTokens, pricing.ModeFast, isBYOK)
+			if resp.Usage != nil {
+				resp.Usage.Cost = cost
+			}
+			if isBYOK {
+				record.APIKeyID = m.byok.ActiveKeyID(userID, string(provider))
+			}
+			m.byok.RecordUsage(userID, projectID, string(provider), modelUsed, isBYOK,
+				inputTokens, outputTokens, cost, string(req.Capability), resp.Duration, "success")
+		}
+		record.Cost = cost
+	}
+	if reservation != nil {
+		_ = m.byok.FinalizeCredits(reservation, cost)
+	}
+
+	// Recorded outside the request context so a call that timed out is kept
+	if err := m.db.Create(record).Error; err != nil {
+		log.Printf("metered AI: failed to record request %s for user %d: %v", req.ID, userID, err)
+	}
+	if genErr == nil && m.usage != nil {
+		if err := m.usage.RecordAIRequest(context.Background(), userID, projectID, record.Provider, record.TokensUsed); err != nil {
+			log.Printf("metered AI: failed to record usage for user %d: %v", userID, err)
+		}
+	}
+	return resp, genErr
+}
+
+// respondAIFailure writes the response for a failed metered AI call: 402 when
+// the caller is out of credits, otherwise 502 with message
+func respondAIFailure(c *gin.Context, err error, message string) {
+	if errors.Is(err, ErrAICreditsExhausted) {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient credits for this request", "code": "INSUFFICIENT_CREDITS"})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": message})
+}
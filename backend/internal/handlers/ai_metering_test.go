@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"apex-build/internal/ai"
+	"apex-build/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeMeteredRouter struct {
+	err error
+}
+
+func (r *fakeMeteredRouter) Generate(ctx context.Context, req *ai.AIRequest) (*ai.AIResponse, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &ai.AIResponse{
+		ID:       req.ID,
+		Provider: ai.ProviderClaude,
+		Content:  "fixed",
+		Usage:    &ai.Usage{PromptTokens: 40, CompletionTokens: 10, TotalTokens: 50, Cost: 0.002},
+	}, nil
+}
+
+func (r *fakeMeteredRouter) GetDefaultProvider(ai.AICapability) ai.AIProvider {
+	return ai.ProviderClaude
+}
+
+func TestMeteredAIRecordsEveryCallForTheCaller(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.AIRequest{}))
+
+	router := &fakeMeteredRouter{}
+	metered := &MeteredAI{db: db, router: router}
+
+	_, err = metered.Generate(context.Background(), &ai.AIRequest{ID: "no-user", Prompt: "hi"})
+	require.Error(t, err)
+
+	resp, err := metered.Generate(context.Background(), &ai.AIRequest{
+		ID: "ok", Capability: ai.CapabilityDebugging, Prompt: "why", UserID: "7", ProjectID: "3",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "fixed", resp.Content)
+
+	router.err = errors.New("provider down")
+	_, err = metered.Generate(context.Background(), &ai.AIRequest{ID: "down", Capability: ai.CapabilityDebugging, UserID: "7"})
+	require.Error(t, err)
+
+	var rows []models.AIRequest
+	require.NoError(t, db.Order("id").Find(&rows).Error)
+	require.Len(t, rows, 2)
+	require.Equal(t, uint(7), rows[0].UserID)
+	require.NotNil(t, rows[0].ProjectID)
+	require.Equal(t, uint(3), *rows[0].ProjectID)
+	require.Equal(t, "completed", rows[0].Status)
+	require.Equal(t, 50, rows[0].TokensUsed)
+	require.InDelta(t, 0.002, rows[0].Cost, 1e-9)
+	require.Equal(t, "failed", rows[1].Status)
+	require.Equal(t, "provider down", rows[1].ErrorMsg)
+}
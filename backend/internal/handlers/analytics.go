@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"apex-build/internal/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyticsHandler exposes per-user AI usage analytics.
+type AnalyticsHandler struct {
+	service *analytics.Service
+}
+
+// NewAnalyticsHandler creates a new AnalyticsHandler.
+func NewAnalyticsHandler(service *analytics.Service) *AnalyticsHandler {
+	return &AnalyticsHandler{service: service}
+}
+
+// GetAIAnalytics returns requests, tokens, cost, latency, and error rates for
+// the authenticated user grouped by day/provider/model.
+// GET /ai/analytics?from=2026-01-01&to=2026-01-31&group_by=day,provider&format=csv
+func (h *AnalyticsHandler) GetAIAnalytics(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+
+	// Default: last 30 days
+	from := now.AddDate(0, 0, -30)
+	to := now
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must be YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must not be before from"})
+		return
+	}
+	if to.Sub(from) > analytics.MaxQueryDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "date range is limited to one year"})
+		return
+	}
+
+	opts := analytics.QueryOpts{
+		UserID:   userID,
+		From:     from,
+		To:       to,
+		GroupBy:  analytics.NormalizeGroupBy(c.Query("group_by")),
+		Provider: strings.TrimSpace(c.Query("provider")),
+		Model:    strings.TrimSpace(c.Query("model")),
+	}
+
+	if strings.EqualFold(c.Query("format"), "csv") {
+		csvData, err := h.service.ExportCSV(c.Request.Context(), opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to export AI analytics",
+			})
+			return
+		}
+		filename := "apex-ai-analytics-" + from.Format("20060102") + "-" + to.Format("20060102") + ".csv"
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", csvData)
+		return
+	}
+
+	rows, totals, err := h.service.Query(c.Request.Context(), opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to load AI analytics",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from":     from.Format("2006-01-02"),
+			"to":       to.Format("2006-01-02"),
+			"group_by": opts.GroupBy,
+			"rows":     rows,
+			"totals":   totals,
+		},
+	})
+}
+
+// RegisterRoutes registers the analytics endpoints under the given router group.
+// The route lives beside /ai/* but outside the AI quota middleware: reading
+// usage history must keep working after a user exhausts their quota.
+func (h *AnalyticsHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/ai/analytics", h.GetAIAnalytics)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/archival"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchivalHandler serves project archive/unarchive to cold storage.
+type ArchivalHandler struct {
+	service    *archival.Service
+	invalidate ProjectCacheInvalidator
+}
+
+// NewArchivalHandler creates a new ArchivalHandler.
+func NewArchivalHandler(service *archival.Service) *ArchivalHandler {
+	return &ArchivalHandler{service: service}
+}
+
+// SetProjectCacheInvalidator wires the project cache so listings reflect the
+// archived state immediately.
+func (h *ArchivalHandler) SetProjectCacheInvalidator(inv ProjectCacheInvalidator) {
+	h.invalidate = inv
+}
+
+// ArchiveProject moves a project's files to cold storage.
+// POST /projects/:id/archive
+func (h *ArchivalHandler) ArchiveProject(c *gin.Context) {
+	h.run(c, h.service.Archive)
+}
+
+// UnarchiveProject restores a project's files from cold storage.
+// POST /projects/:id/unarchive
+func (h *ArchivalHandler) UnarchiveProject(c *gin.Context) {
+	h.run(c, h.service.Unarchive)
+}
+
+func (h *ArchivalHandler) run(c *gin.Context, op func(ctx context.Context, userID, projectID uint) (*archival.Result, error)) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project ID"})
+		return
+	}
+
+	result, err := op(c.Request.Context(), userID, uint(projectID))
+	if err != nil {
+		switch {
+		case errors.Is(err, archival.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Project not found or access denied"})
+		case errors.Is(err, archival.ErrAlreadyArchived), errors.Is(err, archival.ErrNotArchived):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, archival.ErrStorageUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Project archival failed"})
+		}
+		return
+	}
+
+	if h.invalidate != nil {
+		h.invalidate.InvalidateProjectCache(c.Request.Context(), userID, uint(projectID))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// RegisterRoutes registers the archive endpoints. unarchiveGuard runs before
+// unarchive so bringing a project back counts against the project quota.
+func (h *ArchivalHandler) RegisterRoutes(rg *gin.RouterGroup, unarchiveGuard ...gin.HandlerFunc) {
+	rg.POST("/projects/:id/archive", h.ArchiveProject)
+	rg.POST("/projects/:id/unarchive", append(unarchiveGuard, h.UnarchiveProject)...)
+}
+
+// rejectArchivedProject responds 409 and returns true when the project is
+// archived. Previews and executions need files that live in cold storage.
+func rejectArchivedProject(c *gin.Context, project *models.Project) bool {
+	if project == nil || !project.IsArchived {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"success": false,
+		"error":   "Project is archived; unarchive it to run previews or executions",
+		"code":    "PROJECT_ARCHIVED",
+	})
+	return true
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -18,6 +19,7 @@ type BuildKiller interface {
 type BudgetHandler struct {
 	enforcer    *budget.BudgetEnforcer
 	buildKiller BuildKiller
+	anomalies   *budget.AnomalyDetector
 }
 
 // NewBudgetHandler creates a new BudgetHandler.
@@ -30,6 +32,11 @@ func (h *BudgetHandler) SetBuildKiller(bk BuildKiller) {
 	h.buildKiller = bk
 }
 
+// SetAnomalyDetector wires the spend anomaly detector behind /budget/anomalies.
+func (h *BudgetHandler) SetAnomalyDetector(d *budget.AnomalyDetector) {
+	h.anomalies = d
+}
+
 // GetCaps returns all active budget caps for the authenticated user.
 // GET /budget/caps
 func (h *BudgetHandler) GetCaps(c *gin.Context) {
@@ -156,6 +163,61 @@ func (h *BudgetHandler) KillAll(c *gin.Context) {
 	})
 }
 
+// ListAnomalies returns spend anomalies flagged for the authenticated user.
+// GET /budget/anomalies
+func (h *BudgetHandler) ListAnomalies(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if h.anomalies == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "anomaly detection is not enabled"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	items, err := h.anomalies.ListAnomalies(c.Request.Context(), userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anomalies": items})
+}
+
+// OverrideAnomaly resumes the build or re-enables the key paused by an anomaly.
+// POST /budget/anomalies/:id/override
+func (h *BudgetHandler) OverrideAnomaly(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if h.anomalies == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "anomaly detection is not enabled"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anomaly id"})
+		return
+	}
+
+	anomaly, err := h.anomalies.Override(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		if errors.Is(err, budget.ErrAnomalyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anomaly": anomaly})
+}
+
 // RegisterRoutes registers all budget endpoints under the given router group.
 func (h *BudgetHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	bg := rg.Group("/budget")
@@ -165,5 +227,7 @@ func (h *BudgetHandler) RegisterRoutes(rg *gin.RouterGroup) {
 		bg.DELETE("/caps/:id", h.DeleteCap)
 		bg.GET("/preauthorize", h.PreAuthorize)
 		bg.POST("/kill-all", h.KillAll)
+		bg.GET("/anomalies", h.ListAnomalies)
+		bg.POST("/anomalies/:id/override", h.OverrideAnomaly)
 	}
 }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/buildfailures"
+	"apex-build/internal/collaboration"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildFailureHandler serves the failure-cause and flaky-build reports built
+// from recorded build outcomes.
+type BuildFailureHandler struct {
+	service  *buildfailures.Service
+	orgPerms collaboration.OrgPermissions
+}
+
+// NewBuildFailureHandler creates a new BuildFailureHandler.
+func NewBuildFailureHandler(service *buildfailures.Service) *BuildFailureHandler {
+	return &BuildFailureHandler{service: service}
+}
+
+// SetOrgPermissions enables organization reports for members with
+// projects:manage.
+func (h *BuildFailureHandler) SetOrgPermissions(perms collaboration.OrgPermissions) {
+	h.orgPerms = perms
+}
+
+// GetBuildFailureReport reports the caller's top failure causes and flaky
+// descriptions, or an organization's when organization_id is given.
+// GET /build-insights/failures?days=&limit=&organization_id=
+func (h *BuildFailureHandler) GetBuildFailureReport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	scope := buildfailures.Scope{UserID: userID}
+	if raw := c.Query("organization_id"); raw != "" {
+		orgID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || orgID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid organization id"})
+			return
+		}
+		if h.orgPerms == nil || !h.orgPerms.HasPermission(uint(orgID), userID, "projects", "manage") {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied to this organization"})
+			return
+		}
+		scope = buildfailures.Scope{OrganizationID: uint(orgID)}
+	}
+	h.respondReport(c, scope)
+}
+
+// GetAdminBuildFailureReport reports failure causes and flaky descriptions
+// across the platform, or for one user or organization.
+// GET /admin/build-insights/failures?days=&limit=&user_id=&organization_id=
+func (h *BuildFailureHandler) GetAdminBuildFailureReport(c *gin.Context) {
+	scope := buildfailures.Scope{All: true}
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid user id"})
+			return
+		}
+		scope = buildfailures.Scope{UserID: uint(id)}
+	} else if raw := c.Query("organization_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid organization id"})
+			return
+		}
+		scope = buildfailures.Scope{OrganizationID: uint(id)}
+	}
+	h.respondReport(c, scope)
+}
+
+func (h *BuildFailureHandler) respondReport(c *gin.Context, scope buildfailures.Scope) {
+	window := buildfailures.DefaultWindow
+	if raw := c.Query("days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		maxDays := int(buildfailures.MaxWindow / (24 * time.Hour))
+		if err != nil || days <= 0 || days > maxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "days must be between 1 and " + strconv.Itoa(maxDays)})
+			return
+		}
+		window = time.Duration(days) * 24 * time.Hour
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	until := time.Now().UTC()
+	report, err := h.service.Report(c.Request.Context(), scope, until.Add(-window), until, limit)
+	if err != nil {
+		if errors.Is(err, buildfailures.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to build failure report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// RegisterRoutes registers the user and organization report endpoint.
+func (h *BuildFailureHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/build-insights/failures", h.GetBuildFailureReport)
+}
+
+// RegisterAdminRoutes registers the platform-wide report endpoint.
+func (h *BuildFailureHandler) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/build-insights/failures", h.GetAdminBuildFailureReport)
+}
@@ -34,10 +34,11 @@ func (h *BYOKHandlers) SaveKey(c *gin.Context) {
 	}
 
 	var req struct {
-		Provider        string `json:"provider" binding:"required"`
-		APIKey          string `json:"api_key" binding:"required"`
-		ModelPreference string `json:"model_preference"`
-		ProjectID       *uint  `json:"project_id"` // optional: scope key to a specific project
+		Provider         string            `json:"provider" binding:"required"`
+		APIKey           string            `json:"api_key" binding:"required"`
+		ModelPreference  string            `json:"model_preference"`
+		CapabilityModels map[string]string `json:"capability_models"` // optional: per-capability models
+		ProjectID        *uint             `json:"project_id"`        // optional: scope key to a specific project
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: provider and api_key are required"})
@@ -51,10 +52,21 @@ func (h *BYOKHandlers) SaveKey(c *gin.Context) {
 		return
 	}
 
+	if _, err := ai.NormalizeCapabilityModels(req.Provider, req.CapabilityModels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := h.byokManager.SaveKeyForProject(userID, req.Provider, req.APIKey, req.ModelPreference, req.ProjectID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save API key: " + err.Error()})
 		return
 	}
+	if req.CapabilityModels != nil {
+		if _, err := h.byokManager.UpdateCapabilityModels(userID, req.Provider, req.CapabilityModels); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save model preferences: " + err.Error()})
+			return
+		}
+	}
 
 	resp := gin.H{
 		"success":  true,
@@ -98,28 +110,30 @@ func (h *BYOKHandlers) GetKeys(c *gin.Context) {
 
 	// Return safe metadata only (no raw keys, encrypted values, salts, or fingerprints)
 	type KeyInfo struct {
-		Provider        string     `json:"provider"`
-		ProjectID       *uint      `json:"project_id,omitempty"`
-		ModelPreference string     `json:"model_preference"`
-		IsActive        bool       `json:"is_active"`
-		IsValid         bool       `json:"is_valid"`
-		LastUsed        *time.Time `json:"last_used,omitempty"`
-		UsageCount      int64      `json:"usage_count"`
-		TotalCost       float64    `json:"total_cost"`
-		RotationAgeDays *int       `json:"rotation_age_days,omitempty"`
+		Provider         string            `json:"provider"`
+		ProjectID        *uint             `json:"project_id,omitempty"`
+		ModelPreference  string            `json:"model_preference"`
+		CapabilityModels map[string]string `json:"capability_models,omitempty"`
+		IsActive         bool              `json:"is_active"`
+		IsValid          bool              `json:"is_valid"`
+		LastUsed         *time.Time        `json:"last_used,omitempty"`
+		UsageCount       int64             `json:"usage_count"`
+		TotalCost        float64           `json:"total_cost"`
+		RotationAgeDays  *int              `json:"rotation_age_days,omitempty"`
 	}
 
 	keyInfos := make([]KeyInfo, len(keys))
 	for i, k := range keys {
 		info := KeyInfo{
-			Provider:        k.Provider,
-			ProjectID:       k.ProjectID,
-			ModelPreference: k.ModelPreference,
-			IsActive:        k.IsActive,
-			IsValid:         k.IsValid,
-			LastUsed:        k.LastUsed,
-			UsageCount:      k.UsageCount,
-			TotalCost:       k.TotalCost,
+			Provider:         k.Provider,
+			ProjectID:        k.ProjectID,
+			ModelPreference:  k.ModelPreference,
+			CapabilityModels: k.CapabilityModels,
+			IsActive:         k.IsActive,
+			IsValid:          k.IsValid,
+			LastUsed:         k.LastUsed,
+			UsageCount:       k.UsageCount,
+			TotalCost:        k.TotalCost,
 		}
 		// Compute rotation_age_days from last_rotated_at
 		if k.LastRotatedAt != nil {
@@ -195,7 +209,8 @@ func (h *BYOKHandlers) ValidateKey(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// UpdateKeySettings updates is_active and/or model_preference for a provider
+// UpdateKeySettings updates is_active, model_preference and/or the
+// per-capability capability_models for a provider
 func (h *BYOKHandlers) UpdateKeySettings(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	if userID == 0 {
@@ -213,24 +228,38 @@ func (h *BYOKHandlers) UpdateKeySettings(c *gin.Context) {
 	}
 
 	var req struct {
-		IsActive        *bool   `json:"is_active"`
-		ModelPreference *string `json:"model_preference"`
+		IsActive         *bool             `json:"is_active"`
+		ModelPreference  *string           `json:"model_preference"`
+		CapabilityModels map[string]string `json:"capability_models"` // replaces all scopes; {} clears them
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	if _, err := ai.NormalizeCapabilityModels(provider, req.CapabilityModels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := h.byokManager.UpdateKeySettings(userID, provider, req.IsActive, req.ModelPreference); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"success":  true,
 		"message":  "Settings updated",
 		"provider": provider,
-	})
+	}
+	if req.CapabilityModels != nil {
+		capabilityModels, err := h.byokManager.UpdateCapabilityModels(userID, provider, req.CapabilityModels)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		resp["capability_models"] = capabilityModels
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetUsage returns usage summary for the current user
@@ -270,7 +299,8 @@ func (h *BYOKHandlers) GetUsage(c *gin.Context) {
 	})
 }
 
-// GetModels returns available models per provider
+// GetModels returns available models per provider, the same models with
+// per-1M-token cost hints, and the scopes accepted in capability_models
 func (h *BYOKHandlers) GetModels(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	if userID == 0 {
@@ -281,8 +311,18 @@ func (h *BYOKHandlers) GetModels(c *gin.Context) {
 		return
 	}
 
+	buildScopes := make([]string, 0, len(ai.BuildRoleModelScopes))
+	for _, role := range ai.BuildRoleModelScopes {
+		buildScopes = append(buildScopes, ai.BuildRoleModelScope(role))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    ai.GetAvailableModels(),
+		"catalog": ai.GetModelCatalog(),
+		"scopes": gin.H{
+			"capabilities": []string{ai.ModelScopeCompletions, ai.ModelScopeChat, ai.ModelScopeBuild},
+			"build_roles":  buildScopes,
+		},
 	})
 }
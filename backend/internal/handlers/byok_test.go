@@ -68,3 +68,36 @@ func TestBYOKHandlersAllowPaidPlan(t *testing.T) {
 	require.Equal(t, http.StatusOK, recorder.Code)
 	require.Contains(t, recorder.Body.String(), `"success":true`)
 }
+
+func TestBYOKUpdateKeySettingsValidatesCapabilityModels(t *testing.T) {
+	handler, userID := newBYOKHandlerTestFixture(t, "builder")
+	db := handler.byokManager.DB()
+	require.NoError(t, db.Create(&models.UserAPIKey{
+		UserID: userID, Provider: "claude", EncryptedKey: "enc", KeySalt: "salt", KeyFingerprint: "fp", IsActive: true,
+	}).Error)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodPatch, "/byok/keys/claude", strings.NewReader(body))
+		context.Request.Header.Set("Content-Type", "application/json")
+		context.Params = gin.Params{{Key: "provider", Value: "claude"}}
+		context.Set("user_id", userID)
+		handler.UpdateKeySettings(context)
+		return recorder
+	}
+
+	recorder := patch(`{"capability_models":{"completions":"gpt-4.1"}}`)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = patch(`{"capability_models":{"completions":"claude-haiku-4-5-20251001","build:reviewer":"claude-opus-4-7"}}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	prefs := ai.LoadCapabilityModelPreferences(db, userID)
+	require.Equal(t, "claude-haiku-4-5-20251001", prefs["claude"][ai.ModelScopeCompletions])
+	require.Equal(t, "claude-opus-4-7", prefs["claude"][ai.BuildRoleModelScope("reviewer")])
+
+	recorder = patch(`{"capability_models":{}}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Nil(t, ai.LoadCapabilityModelPreferences(db, userID))
+}
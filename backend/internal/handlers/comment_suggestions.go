@@ -0,0 +1,261 @@
+// APEX.BUILD Code Comment Suggestions
+// Suggested changes on comments, applied to the file in one click
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/activity"
+	"apex-build/internal/collaboration"
+	"apex-build/internal/middleware"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ApplySuggestion accepts a comment's suggested change: the lines it covers
+// are replaced, a file version is recorded and the thread is resolved.
+// POST /comments/:id/apply
+func (ch *CommentsHandler) ApplySuggestion(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+
+	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid comment ID",
+			Code:    "INVALID_COMMENT_ID",
+		})
+		return
+	}
+
+	var comment models.CodeComment
+	if err := ch.DB.First(&comment, uint(commentID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, StandardResponse{
+				Success: false,
+				Error:   "Comment not found",
+				Code:    "COMMENT_NOT_FOUND",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Database error",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if comment.Suggestion == nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Comment has no suggested change",
+			Code:    "NO_SUGGESTION",
+		})
+		return
+	}
+	if comment.SuggestionAppliedAt != nil {
+		c.JSON(http.StatusConflict, StandardResponse{
+			Success: false,
+			Error:   "Suggested change was already applied",
+			Code:    "SUGGESTION_APPLIED",
+		})
+		return
+	}
+
+	var file models.File
+	if err := ch.DB.First(&file, comment.FileID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, StandardResponse{
+				Success: false,
+				Error:   "File not found",
+				Code:    "FILE_NOT_FOUND",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Database error",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	allowed, err := ch.canApplySuggestion(userID, file.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to check project access",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, StandardResponse{
+			Success: false,
+			Error:   "Only the project owner or editors can apply suggested changes",
+			Code:    "ACCESS_DENIED",
+		})
+		return
+	}
+
+	// The suggestion only applies to the lines it was written against
+	current, ok := commentRangeLines(file.Content, comment.StartLine, comment.EndLine)
+	if !ok || current != comment.SuggestionBase {
+		c.JSON(http.StatusConflict, StandardResponse{
+			Success: false,
+			Error:   "The lines this suggestion changes have been edited since it was made",
+			Code:    "SUGGESTION_OUTDATED",
+		})
+		return
+	}
+
+	var user models.User
+	ch.DB.First(&user, userID)
+
+	content := applyLineSuggestion(file.Content, comment.StartLine, comment.EndLine, *comment.Suggestion)
+	now := time.Now()
+	var versionID uint
+	err = ch.DB.Transaction(func(tx *gorm.DB) error {
+		// Guard on the version read above so a concurrent save is not overwritten
+		result := tx.Model(&models.File{}).
+			Where("id = ? AND version = ?", file.ID, file.Version).
+			Updates(map[string]interface{}{
+				"content":      content,
+				"size":         int64(len(content)),
+				"last_edit_by": userID,
+				"version":      gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSuggestionOutdated
+		}
+
+		file.Content = content
+		file.Size = int64(len(content))
+		summary := fmt.Sprintf("Applied suggestion from %s (comment #%d)", comment.AuthorName, comment.ID)
+		versionID = CreateFileVersion(tx, &file, userID, user.Username, "suggestion", summary)
+
+		if err := tx.Model(&models.CodeComment{}).Where("id = ?", comment.ID).
+			Updates(map[string]interface{}{
+				"suggestion_applied_at":    &now,
+				"suggestion_applied_by_id": userID,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CodeComment{}).
+			Where("thread_id = ?", comment.ThreadID).
+			Updates(map[string]interface{}{
+				"is_resolved":    true,
+				"resolved_at":    &now,
+				"resolved_by_id": userID,
+			}).Error
+	})
+	if errors.Is(err, errSuggestionOutdated) {
+		c.JSON(http.StatusConflict, StandardResponse{
+			Success: false,
+			Error:   "The file changed while the suggestion was being applied",
+			Code:    "SUGGESTION_OUTDATED",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to apply suggested change",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	recordActivity(c, ch.Activity, &activity.Event{
+		ProjectID: file.ProjectID,
+		ActorID:   userID,
+		ActorName: user.Username,
+		Kind:      activity.KindComment,
+		Action:    "applied",
+		Target:    file.Path,
+		Summary:   fmt.Sprintf("applied a suggestion from %s on %s:%d", comment.AuthorName, file.Path, comment.StartLine),
+		Ref:       fmt.Sprintf("suggestion:%d", comment.ID),
+		Metadata:  map[string]any{"comment_id": comment.ID, "thread_id": comment.ThreadID},
+	})
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Suggested change applied",
+		Data: map[string]interface{}{
+			"comment_id":   comment.ID,
+			"thread_id":    comment.ThreadID,
+			"file_id":      file.ID,
+			"file_version": file.Version + 1,
+			"version_id":   versionID,
+			"content":      content,
+			"resolved_at":  now,
+		},
+	})
+}
+
+var errSuggestionOutdated = errors.New("suggestion outdated")
+
+// canApplySuggestion reports whether the user may edit the project's files:
+// its owner, or a collaborator with editor access
+func (ch *CommentsHandler) canApplySuggestion(userID, projectID uint) (bool, error) {
+	if ch.Access == nil {
+		var project models.Project
+		if err := ch.DB.Select("id", "owner_id").First(&project, projectID).Error; err != nil {
+			return false, err
+		}
+		return project.OwnerID == userID, nil
+	}
+	access, err := ch.Access(userID, projectID)
+	if errors.Is(err, collaboration.ErrProjectAccessDenied) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch access.Permission {
+	case collaboration.PermissionOwner, collaboration.PermissionAdmin, collaboration.PermissionEditor:
+		return true, nil
+	}
+	return false, nil
+}
+
+// commentRangeLines returns lines start..end (1-based, inclusive) of content,
+// or false when the range falls outside it
+func commentRangeLines(content string, start, end int) (string, bool) {
+	lines := strings.Split(content, "\n")
+	if start < 1 || end < start || end > len(lines) {
+		return "", false
+	}
+	return strings.Join(lines[start-1:end], "\n"), true
+}
+
+// applyLineSuggestion replaces lines start..end of content with replacement.
+// An empty replacement deletes the lines.
+func applyLineSuggestion(content string, start, end int, replacement string) string {
+	lines := strings.Split(content, "\n")
+	patched := append([]string{}, lines[:start-1]...)
+	if replacement != "" {
+		patched = append(patched, strings.Split(replacement, "\n")...)
+	}
+	patched = append(patched, lines[end:]...)
+	return strings.Join(patched, "\n")
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/internal/notifications"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newCommentsTestFixture(t *testing.T) (*CommentsHandler, *gorm.DB, models.User, models.File) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}, &models.FileVersion{}, &models.CodeComment{}, &notifications.Notification{}))
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&owner).Error)
+	project := models.Project{Name: "Review", Language: "typescript", OwnerID: owner.ID, IsPublic: true}
+	require.NoError(t, db.Create(&project).Error)
+	file := models.File{ProjectID: project.ID, Name: "app.ts", Path: "src/app.ts", Type: "file",
+		Content: "const a = 1\nconst b = 2\nconsole.log(a + b)\n", Version: 1}
+	require.NoError(t, db.Create(&file).Error)
+
+	return NewCommentsHandler(db), db, owner, file
+}
+
+func serveComment(t *testing.T, handler gin.HandlerFunc, userID uint, commentID uint, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	if commentID == 0 {
+		return serveCommentRequest(t, handler, http.MethodPost, "/comments", nil, userID, body)
+	}
+	params := gin.Params{{Key: "id", Value: fmt.Sprint(commentID)}}
+	return serveCommentRequest(t, handler, http.MethodPost, fmt.Sprintf("/comments/%d/apply", commentID), params, userID, body)
+}
+
+func serveCommentRequest(t *testing.T, handler gin.HandlerFunc, method, target string, params gin.Params, userID uint, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Params = params
+	context.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	context.Request.Header.Set("Content-Type", "application/json")
+	context.Set("user_id", userID)
+	handler(context)
+	return recorder
+}
+
+func TestApplySuggestionPatchesFileAndResolvesThread(t *testing.T) {
+	handler, db, owner, file := newCommentsTestFixture(t)
+	reviewer := models.User{Username: "reviewer", Email: "reviewer@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&reviewer).Error)
+
+	body := fmt.Sprintf(`{"file_id":%d,"project_id":%d,"start_line":2,"end_line":2,"content":"use let","suggestion":"let b = 2\nb++"}`, file.ID, file.ProjectID)
+	recorder := serveComment(t, handler.CreateComment, reviewer.ID, 0, body)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var created struct {
+		Data CommentResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	require.NotNil(t, created.Data.Suggestion)
+
+	outOfRange := fmt.Sprintf(`{"file_id":%d,"project_id":%d,"start_line":9,"end_line":9,"content":"x","suggestion":"y"}`, file.ID, file.ProjectID)
+	require.Equal(t, http.StatusBadRequest, serveComment(t, handler.CreateComment, reviewer.ID, 0, outOfRange).Code)
+
+	// The reviewer may suggest on a public project but not edit it
+	require.Equal(t, http.StatusForbidden, serveComment(t, handler.ApplySuggestion, reviewer.ID, created.Data.ID, "").Code)
+
+	recorder = serveComment(t, handler.ApplySuggestion, owner.ID, created.Data.ID, "")
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var updated models.File
+	require.NoError(t, db.First(&updated, file.ID).Error)
+	require.Equal(t, "const a = 1\nlet b = 2\nb++\nconsole.log(a + b)\n", updated.Content)
+	require.Equal(t, 2, updated.Version)
+
+	var version models.FileVersion
+	require.NoError(t, db.Where("file_id = ?", file.ID).First(&version).Error)
+	require.Equal(t, "suggestion", version.ChangeType)
+
+	var comment models.CodeComment
+	require.NoError(t, db.First(&comment, created.Data.ID).Error)
+	require.True(t, comment.IsResolved)
+	require.NotNil(t, comment.SuggestionAppliedAt)
+	require.Equal(t, http.StatusConflict, serveComment(t, handler.ApplySuggestion, owner.ID, created.Data.ID, "").Code)
+}
+
+func TestApplySuggestionRefusesEditedLines(t *testing.T) {
+	handler, db, owner, file := newCommentsTestFixture(t)
+
+	body := fmt.Sprintf(`{"file_id":%d,"project_id":%d,"start_line":1,"end_line":2,"content":"drop these","suggestion":""}`, file.ID, file.ProjectID)
+	recorder := serveComment(t, handler.CreateComment, owner.ID, 0, body)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var created struct {
+		Data CommentResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+
+	require.NoError(t, db.Model(&models.File{}).Where("id = ?", file.ID).
+		Update("content", "const a = 10\nconst b = 2\nconsole.log(a + b)\n").Error)
+	recorder = serveComment(t, handler.ApplySuggestion, owner.ID, created.Data.ID, "")
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, recorder.Body.String(), "SUGGESTION_OUTDATED")
+
+	require.Equal(t, "console.log(a + b)\n", applyLineSuggestion(file.Content, 1, 2, ""))
+}
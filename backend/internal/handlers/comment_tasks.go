@@ -0,0 +1,563 @@
+// APEX.BUILD Code Comment Tasks
+// @mentions, assignment and review requests, so threads work as lightweight tasks
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/middleware"
+	"apex-build/internal/notifications"
+	"apex-build/internal/pagination"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Review states of a comment thread
+const (
+	ReviewRequested        = "requested"
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+)
+
+// commentListSpec pages the project-wide thread listing, newest first
+var commentListSpec = pagination.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "updated_at", Column: "updated_at", Desc: true},
+	},
+}
+
+var commentMentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// AssignCommentRequest assigns a thread; a null assignee unassigns it
+type AssignCommentRequest struct {
+	AssigneeID *uint `json:"assignee_id"`
+}
+
+// ReviewCommentRequest requests a review of a thread or records the verdict
+type ReviewCommentRequest struct {
+	State      string `json:"state"` // requested, approved, changes_requested, or "" to clear
+	ReviewerID *uint  `json:"reviewer_id"`
+}
+
+// AssignThread sets or clears the collaborator responsible for a thread
+// PUT /comments/:id/assign
+func (ch *CommentsHandler) AssignThread(c *gin.Context) {
+	userID, root, ok := ch.loadThreadForTriage(c)
+	if !ok {
+		return
+	}
+
+	var req AssignCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid request format: " + err.Error(),
+			Code:    "INVALID_REQUEST",
+		})
+		return
+	}
+	if req.AssigneeID != nil && !ch.isProjectCollaborator(*req.AssigneeID, root.ProjectID) {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Assignee must be a collaborator on the project",
+			Code:    "INVALID_ASSIGNEE",
+		})
+		return
+	}
+
+	if err := ch.DB.Model(&models.CodeComment{}).Where("id = ?", root.ID).
+		Update("assignee_id", req.AssigneeID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to assign thread",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	root.AssigneeID = req.AssigneeID
+
+	if req.AssigneeID != nil && *req.AssigneeID != userID {
+		actor := ch.username(userID)
+		ch.notifyComment(c, *req.AssigneeID, "comment_assigned",
+			fmt.Sprintf("%s assigned you a comment", actor),
+			fmt.Sprintf("%s: %s", ch.commentLocation(root), truncateCommentText(root.Content)), root)
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Thread assignment updated",
+		Data:    commentToResponse(root, nil),
+	})
+}
+
+// SetReviewState requests a review of a thread from a collaborator, or lets
+// the reviewer approve it or ask for changes
+// PUT /comments/:id/review
+func (ch *CommentsHandler) SetReviewState(c *gin.Context) {
+	userID, root, ok := ch.loadThreadForTriage(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid request format: " + err.Error(),
+			Code:    "INVALID_REQUEST",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{"review_state": req.State}
+	switch req.State {
+	case ReviewRequested:
+		if req.ReviewerID == nil || !ch.isProjectCollaborator(*req.ReviewerID, root.ProjectID) {
+			c.JSON(http.StatusBadRequest, StandardResponse{
+				Success: false,
+				Error:   "Reviewer must be a collaborator on the project",
+				Code:    "INVALID_REVIEWER",
+			})
+			return
+		}
+		updates["reviewer_id"] = *req.ReviewerID
+	case ReviewApproved, ReviewChangesRequested:
+		if root.ReviewState == "" {
+			c.JSON(http.StatusConflict, StandardResponse{
+				Success: false,
+				Error:   "No review was requested on this thread",
+				Code:    "REVIEW_NOT_REQUESTED",
+			})
+			return
+		}
+		if (root.ReviewerID == nil || *root.ReviewerID != userID) && !ch.isProjectOwner(userID, root.ProjectID) {
+			c.JSON(http.StatusForbidden, StandardResponse{
+				Success: false,
+				Error:   "Only the requested reviewer or project owner can complete a review",
+				Code:    "ACCESS_DENIED",
+			})
+			return
+		}
+	case "":
+		updates["reviewer_id"] = nil
+	default:
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "State must be requested, approved, changes_requested or empty",
+			Code:    "INVALID_REVIEW_STATE",
+		})
+		return
+	}
+
+	if err := ch.DB.Model(&models.CodeComment{}).Where("id = ?", root.ID).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to update review",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	ch.DB.First(root, root.ID)
+
+	actor := ch.username(userID)
+	location := ch.commentLocation(root)
+	switch req.State {
+	case ReviewRequested:
+		if *req.ReviewerID != userID {
+			ch.notifyComment(c, *req.ReviewerID, "comment_review_requested",
+				fmt.Sprintf("%s requested your review", actor),
+				fmt.Sprintf("%s: %s", location, truncateCommentText(root.Content)), root)
+		}
+	case ReviewApproved, ReviewChangesRequested:
+		verdict := "approved"
+		if req.State == ReviewChangesRequested {
+			verdict = "requested changes on"
+		}
+		if root.AuthorID != userID {
+			ch.notifyComment(c, root.AuthorID, "comment_review_"+req.State,
+				fmt.Sprintf("%s %s your comment", actor, verdict), location, root)
+		}
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Thread review updated",
+		Data:    commentToResponse(root, nil),
+	})
+}
+
+// GetProjectComments lists a project's comment threads across files, for
+// triage: ?status=open&assigned=me&review=requested&reviewer=me
+// GET /comments/project/:projectId
+func (ch *CommentsHandler) GetProjectComments(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid project ID",
+			Code:    "INVALID_PROJECT_ID",
+		})
+		return
+	}
+	if err := ch.checkProjectView(userID, uint(projectID)); err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, StandardResponse{
+				Success: false,
+				Error:   "Project not found",
+				Code:    "PROJECT_NOT_FOUND",
+			})
+		case errors.Is(err, collaboration.ErrProjectAccessDenied):
+			c.JSON(http.StatusForbidden, StandardResponse{
+				Success: false,
+				Error:   "Access denied",
+				Code:    "ACCESS_DENIED",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, StandardResponse{
+				Success: false,
+				Error:   "Database error",
+				Code:    "DATABASE_ERROR",
+			})
+		}
+		return
+	}
+
+	list, err := pagination.Parse(c, commentListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_REQUEST",
+		})
+		return
+	}
+	query, err := applyThreadFilters(ch.DB.Where("project_id = ? AND parent_id IS NULL", uint(projectID)), c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_FILTER",
+		})
+		return
+	}
+
+	var rootComments []models.CodeComment
+	if err := list.Apply(query).Find(&rootComments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to fetch comments",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	rootComments, pageInfo := pagination.Page(list, rootComments, func(comment models.CodeComment) (any, any) {
+		if list.Sort.Key == "updated_at" {
+			return comment.UpdatedAt, comment.ID
+		}
+		return comment.CreatedAt, comment.ID
+	})
+
+	responses := ch.threadResponses(rootComments)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"project_id": uint(projectID),
+			"comments":   responses,
+			"total":      len(responses),
+		},
+		"page_info": pageInfo,
+	})
+}
+
+// applyThreadFilters narrows a root comment query by the status, assigned,
+// review and reviewer query parameters
+func applyThreadFilters(query *gorm.DB, c *gin.Context, userID uint) (*gorm.DB, error) {
+	switch c.Query("status") {
+	case "", "all":
+	case "open":
+		query = query.Where("is_resolved = ?", false)
+	case "resolved":
+		query = query.Where("is_resolved = ?", true)
+	default:
+		return nil, fmt.Errorf("status must be open, resolved or all")
+	}
+
+	switch assigned := c.Query("assigned"); assigned {
+	case "":
+	case "me":
+		query = query.Where("assignee_id = ?", userID)
+	case "none":
+		query = query.Where("assignee_id IS NULL")
+	default:
+		assigneeID, err := strconv.ParseUint(assigned, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("assigned must be me, none or a user id")
+		}
+		query = query.Where("assignee_id = ?", uint(assigneeID))
+	}
+
+	switch review := c.Query("review"); review {
+	case "":
+	case ReviewRequested, ReviewApproved, ReviewChangesRequested:
+		query = query.Where("review_state = ?", review)
+	default:
+		return nil, fmt.Errorf("review must be requested, approved or changes_requested")
+	}
+
+	switch c.Query("reviewer") {
+	case "":
+	case "me":
+		query = query.Where("reviewer_id = ?", userID)
+	default:
+		return nil, fmt.Errorf("reviewer must be me")
+	}
+	return query, nil
+}
+
+// threadResponses attaches each root comment's replies
+func (ch *CommentsHandler) threadResponses(rootComments []models.CodeComment) []CommentResponse {
+	threadIDs := make([]string, len(rootComments))
+	for i, comment := range rootComments {
+		threadIDs[i] = comment.ThreadID
+	}
+
+	var allReplies []models.CodeComment
+	if len(threadIDs) > 0 {
+		ch.DB.Where("thread_id IN ? AND parent_id IS NOT NULL", threadIDs).
+			Order("created_at ASC").
+			Find(&allReplies)
+	}
+	repliesByThread := make(map[string][]models.CodeComment)
+	for _, reply := range allReplies {
+		repliesByThread[reply.ThreadID] = append(repliesByThread[reply.ThreadID], reply)
+	}
+
+	responses := make([]CommentResponse, len(rootComments))
+	for i, comment := range rootComments {
+		responses[i] = *commentToResponse(&comment, repliesByThread[comment.ThreadID])
+	}
+	return responses
+}
+
+// loadThreadForTriage authenticates the caller, loads the root of the
+// thread containing comment :id and checks the caller is a collaborator.
+// It writes the error response itself.
+func (ch *CommentsHandler) loadThreadForTriage(c *gin.Context) (uint, *models.CodeComment, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return 0, nil, false
+	}
+
+	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid comment ID",
+			Code:    "INVALID_COMMENT_ID",
+		})
+		return 0, nil, false
+	}
+
+	var comment models.CodeComment
+	err = ch.DB.First(&comment, uint(commentID)).Error
+	if err == nil && comment.ParentID != nil {
+		err = ch.DB.Where("thread_id = ? AND parent_id IS NULL", comment.ThreadID).First(&comment).Error
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, StandardResponse{
+				Success: false,
+				Error:   "Comment not found",
+				Code:    "COMMENT_NOT_FOUND",
+			})
+			return 0, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Database error",
+			Code:    "DATABASE_ERROR",
+		})
+		return 0, nil, false
+	}
+
+	if !ch.isProjectCollaborator(userID, comment.ProjectID) {
+		c.JSON(http.StatusForbidden, StandardResponse{
+			Success: false,
+			Error:   "Only project collaborators can triage comment threads",
+			Code:    "ACCESS_DENIED",
+		})
+		return 0, nil, false
+	}
+	return userID, &comment, true
+}
+
+// checkProjectView returns nil when the user may read the project's
+// comments: its owner, anyone for public projects, or organization members
+func (ch *CommentsHandler) checkProjectView(userID, projectID uint) error {
+	if ch.Access != nil {
+		_, err := ch.Access(userID, projectID)
+		return err
+	}
+	var project models.Project
+	if err := ch.DB.Select("id", "owner_id", "is_public").First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return collaboration.ErrProjectNotFound
+		}
+		return err
+	}
+	if project.OwnerID != userID && !project.IsPublic {
+		return collaboration.ErrProjectAccessDenied
+	}
+	return nil
+}
+
+// isProjectCollaborator reports whether the user works on the project: its
+// owner or an organization member, not just a reader of a public project
+func (ch *CommentsHandler) isProjectCollaborator(userID, projectID uint) bool {
+	if ch.Access == nil {
+		return ch.isProjectOwner(userID, projectID)
+	}
+	access, err := ch.Access(userID, projectID)
+	if err != nil {
+		return false
+	}
+	return access.Permission != collaboration.PermissionViewer || !access.Public
+}
+
+func (ch *CommentsHandler) isProjectOwner(userID, projectID uint) bool {
+	var project models.Project
+	if err := ch.DB.Select("id", "owner_id").First(&project, projectID).Error; err != nil {
+		return false
+	}
+	return project.OwnerID == userID
+}
+
+// parseCommentMentions returns the distinct @usernames in content, in order
+func parseCommentMentions(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range commentMentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolveMentions maps the @usernames in content to collaborators on the
+// project. Unknown names, outsiders and the author are skipped.
+func (ch *CommentsHandler) resolveMentions(content string, projectID, authorID uint) []uint {
+	names := parseCommentMentions(content)
+	if len(names) == 0 {
+		return nil
+	}
+	var users []models.User
+	if err := ch.DB.Select("id", "username").Where("username IN ?", names).Find(&users).Error; err != nil {
+		log.Printf("comments: failed to resolve mentions: %v", err)
+		return nil
+	}
+	var ids []uint
+	for _, user := range users {
+		if user.ID != authorID && ch.isProjectCollaborator(user.ID, projectID) {
+			ids = append(ids, user.ID)
+		}
+	}
+	return ids
+}
+
+// notifyMentions tells users mentioned in comment, except those already in
+// previous, that they were mentioned
+func (ch *CommentsHandler) notifyMentions(c *gin.Context, comment *models.CodeComment, previous []uint) {
+	already := make(map[uint]bool, len(previous))
+	for _, id := range previous {
+		already[id] = true
+	}
+	for _, id := range comment.Mentions {
+		if already[id] {
+			continue
+		}
+		ch.notifyComment(c, id, "comment_mention",
+			fmt.Sprintf("%s mentioned you in a comment", comment.AuthorName),
+			fmt.Sprintf("%s: %s", ch.commentLocation(comment), truncateCommentText(comment.Content)), comment)
+	}
+}
+
+// notifyComment posts a notification about a comment. Failures are logged:
+// notifications never fail the comment request.
+func (ch *CommentsHandler) notifyComment(c *gin.Context, userID uint, kind, title, body string, comment *models.CodeComment) {
+	if ch.Notifications == nil {
+		return
+	}
+	if err := ch.Notifications.Notify(c.Request.Context(), &notifications.Notification{
+		UserID:   userID,
+		Kind:     kind,
+		Severity: notifications.SeverityInfo,
+		Title:    title,
+		Body:     body,
+		Metadata: map[string]any{
+			"project_id": comment.ProjectID,
+			"file_id":    comment.FileID,
+			"comment_id": comment.ID,
+			"thread_id":  comment.ThreadID,
+			"line":       comment.StartLine,
+		},
+	}); err != nil {
+		log.Printf("comments: failed to notify user %d about comment %d: %v", userID, comment.ID, err)
+	}
+}
+
+// commentLocation describes where a comment sits, e.g. src/app.ts:12
+func (ch *CommentsHandler) commentLocation(comment *models.CodeComment) string {
+	var file models.File
+	if err := ch.DB.Select("id", "path").First(&file, comment.FileID).Error; err != nil || file.Path == "" {
+		return fmt.Sprintf("line %d", comment.StartLine)
+	}
+	return fmt.Sprintf("%s:%d", file.Path, comment.StartLine)
+}
+
+func (ch *CommentsHandler) username(userID uint) string {
+	var user models.User
+	if err := ch.DB.Select("id", "username").First(&user, userID).Error; err != nil {
+		return "Someone"
+	}
+	return user.Username
+}
+
+func truncateCommentText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > 140 {
+		return string(runes[:137]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/notifications"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentMentionsAssignmentAndReviewRequests(t *testing.T) {
+	handler, db, owner, file := newCommentsTestFixture(t)
+	teammate := models.User{Username: "teammate", Email: "teammate@example.com", PasswordHash: "x"}
+	stranger := models.User{Username: "stranger", Email: "stranger@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&teammate).Error)
+	require.NoError(t, db.Create(&stranger).Error)
+
+	handler.Notifications = notifications.NewService(db)
+	handler.Access = func(userID, projectID uint) (*collaboration.ProjectAccess, error) {
+		access := &collaboration.ProjectAccess{ProjectID: projectID, Public: true, Permission: collaboration.PermissionViewer}
+		switch userID {
+		case owner.ID:
+			access.Permission = collaboration.PermissionOwner
+		case teammate.ID:
+			access.Permission = collaboration.PermissionEditor
+		}
+		return access, nil
+	}
+	notificationKinds := func(userID uint) []string {
+		var items []notifications.Notification
+		require.NoError(t, db.Where("user_id = ?", userID).Order("id").Find(&items).Error)
+		kinds := make([]string, len(items))
+		for i, item := range items {
+			kinds[i] = item.Kind
+		}
+		return kinds
+	}
+
+	body := fmt.Sprintf(`{"file_id":%d,"project_id":%d,"start_line":3,"end_line":3,"content":"@teammate can you check this? cc @stranger @owner"}`, file.ID, file.ProjectID)
+	recorder := serveComment(t, handler.CreateComment, owner.ID, 0, body)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var created struct {
+		Data CommentResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	require.Equal(t, []uint{teammate.ID}, created.Data.Mentions)
+	require.Equal(t, []string{"comment_mention"}, notificationKinds(teammate.ID))
+	require.Empty(t, notificationKinds(stranger.ID))
+
+	params := gin.Params{{Key: "id", Value: fmt.Sprint(created.Data.ID)}}
+	assign := func(userID uint, body string) int {
+		return serveCommentRequest(t, handler.AssignThread, http.MethodPut, "/comments/x/assign", params, userID, body).Code
+	}
+	require.Equal(t, http.StatusForbidden, assign(stranger.ID, fmt.Sprintf(`{"assignee_id":%d}`, stranger.ID)))
+	require.Equal(t, http.StatusBadRequest, assign(owner.ID, fmt.Sprintf(`{"assignee_id":%d}`, stranger.ID)))
+	require.Equal(t, http.StatusOK, assign(owner.ID, fmt.Sprintf(`{"assignee_id":%d}`, teammate.ID)))
+
+	review := func(userID uint, body string) int {
+		return serveCommentRequest(t, handler.SetReviewState, http.MethodPut, "/comments/x/review", params, userID, body).Code
+	}
+	require.Equal(t, http.StatusConflict, review(teammate.ID, `{"state":"approved"}`))
+	require.Equal(t, http.StatusOK, review(owner.ID, fmt.Sprintf(`{"state":"requested","reviewer_id":%d}`, teammate.ID)))
+	require.Equal(t, http.StatusOK, review(teammate.ID, `{"state":"approved"}`))
+	require.Equal(t, []string{"comment_mention", "comment_assigned", "comment_review_requested"}, notificationKinds(teammate.ID))
+	require.Equal(t, []string{"comment_review_approved"}, notificationKinds(owner.ID))
+
+	list := func(userID uint, query string) (int, []CommentResponse) {
+		projectParams := gin.Params{{Key: "projectId", Value: fmt.Sprint(file.ProjectID)}}
+		recorder := serveCommentRequest(t, handler.GetProjectComments, http.MethodGet, "/comments/project/x?"+query, projectParams, userID, "")
+		var listed struct {
+			Data struct {
+				Comments []CommentResponse `json:"comments"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listed))
+		return recorder.Code, listed.Data.Comments
+	}
+	code, threads := list(teammate.ID, "status=open&assigned=me")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, threads, 1)
+	require.Equal(t, ReviewApproved, threads[0].ReviewState)
+	_, threads = list(owner.ID, "assigned=me")
+	require.Empty(t, threads)
+	_, threads = list(owner.ID, "status=resolved")
+	require.Empty(t, threads)
+	code, _ = list(owner.ID, "review=maybe")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestParseCommentMentions(t *testing.T) {
+	require.Equal(t, []string{"ada", "bob"}, parseCommentMentions("mail a@b.com, then @ada. and @bob- (@ada again)"))
+	require.Empty(t, parseCommentMentions("no mentions here"))
+}
@@ -9,7 +9,10 @@ import (
 	"strconv"
 	"time"
 
+	"apex-build/internal/activity"
+	"apex-build/internal/collaboration"
 	"apex-build/internal/middleware"
+	"apex-build/internal/notifications"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -19,7 +22,13 @@ import (
 
 // CommentsHandler handles code comment operations
 type CommentsHandler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Activity *activity.Service
+	// Access resolves collaborator permissions; without it only the
+	// project owner may apply suggested changes
+	Access collaboration.AccessResolver
+	// Notifications receives @mentions, assignments and review requests
+	Notifications *notifications.Service
 }
 
 // NewCommentsHandler creates a new comments handler
@@ -29,15 +38,16 @@ func NewCommentsHandler(db *gorm.DB) *CommentsHandler {
 
 // CreateCommentRequest represents a request to create a comment
 type CreateCommentRequest struct {
-	FileID      uint   `json:"file_id" binding:"required"`
-	ProjectID   uint   `json:"project_id" binding:"required"`
-	StartLine   int    `json:"start_line" binding:"required,min=1"`
-	EndLine     int    `json:"end_line" binding:"required,min=1"`
-	StartColumn int    `json:"start_column"`
-	EndColumn   int    `json:"end_column"`
-	Content     string `json:"content" binding:"required,min=1"`
-	ParentID    *uint  `json:"parent_id"` // For replies
-	ThreadID    string `json:"thread_id"` // Existing thread ID for replies
+	FileID      uint    `json:"file_id" binding:"required"`
+	ProjectID   uint    `json:"project_id" binding:"required"`
+	StartLine   int     `json:"start_line" binding:"required,min=1"`
+	EndLine     int     `json:"end_line" binding:"required,min=1"`
+	StartColumn int     `json:"start_column"`
+	EndColumn   int     `json:"end_column"`
+	Content     string  `json:"content" binding:"required,min=1"`
+	ParentID    *uint   `json:"parent_id"`  // For replies
+	ThreadID    string  `json:"thread_id"`  // Existing thread ID for replies
+	Suggestion  *string `json:"suggestion"` // Replacement text for the line range
 }
 
 // UpdateCommentRequest represents a request to update a comment
@@ -52,26 +62,33 @@ type ReactionRequest struct {
 
 // CommentResponse represents a comment in API responses
 type CommentResponse struct {
-	ID           uint                  `json:"id"`
-	FileID       uint                  `json:"file_id"`
-	ProjectID    uint                  `json:"project_id"`
-	StartLine    int                   `json:"start_line"`
-	EndLine      int                   `json:"end_line"`
-	StartColumn  int                   `json:"start_column"`
-	EndColumn    int                   `json:"end_column"`
-	Content      string                `json:"content"`
-	ParentID     *uint                 `json:"parent_id,omitempty"`
-	ThreadID     string                `json:"thread_id"`
-	AuthorID     uint                  `json:"author_id"`
-	AuthorName   string                `json:"author_name"`
-	IsResolved   bool                  `json:"is_resolved"`
-	ResolvedAt   *time.Time            `json:"resolved_at,omitempty"`
-	ResolvedByID *uint                 `json:"resolved_by_id,omitempty"`
-	Reactions    map[string][]uint     `json:"reactions,omitempty"`
-	Replies      []CommentResponse     `json:"replies,omitempty"`
-	ReplyCount   int                   `json:"reply_count"`
-	CreatedAt    time.Time             `json:"created_at"`
-	UpdatedAt    time.Time             `json:"updated_at"`
+	ID                    uint              `json:"id"`
+	FileID                uint              `json:"file_id"`
+	ProjectID             uint              `json:"project_id"`
+	StartLine             int               `json:"start_line"`
+	EndLine               int               `json:"end_line"`
+	StartColumn           int               `json:"start_column"`
+	EndColumn             int               `json:"end_column"`
+	Content               string            `json:"content"`
+	ParentID              *uint             `json:"parent_id,omitempty"`
+	ThreadID              string            `json:"thread_id"`
+	AuthorID              uint              `json:"author_id"`
+	AuthorName            string            `json:"author_name"`
+	IsResolved            bool              `json:"is_resolved"`
+	ResolvedAt            *time.Time        `json:"resolved_at,omitempty"`
+	ResolvedByID          *uint             `json:"resolved_by_id,omitempty"`
+	Reactions             map[string][]uint `json:"reactions,omitempty"`
+	Suggestion            *string           `json:"suggestion,omitempty"`
+	SuggestionAppliedAt   *time.Time        `json:"suggestion_applied_at,omitempty"`
+	SuggestionAppliedByID *uint             `json:"suggestion_applied_by_id,omitempty"`
+	AssigneeID            *uint             `json:"assignee_id,omitempty"`
+	ReviewState           string            `json:"review_state,omitempty"`
+	ReviewerID            *uint             `json:"reviewer_id,omitempty"`
+	Mentions              []uint            `json:"mentions,omitempty"`
+	Replies               []CommentResponse `json:"replies,omitempty"`
+	ReplyCount            int               `json:"reply_count"`
+	CreatedAt             time.Time         `json:"created_at"`
+	UpdatedAt             time.Time         `json:"updated_at"`
 }
 
 // ThreadResponse represents a comment thread
@@ -94,12 +111,16 @@ func (ch *CommentsHandler) RegisterCommentRoutes(rg *gin.RouterGroup) {
 	{
 		comments.POST("", ch.CreateComment)
 		comments.GET("/file/:fileId", ch.GetFileComments)
+		comments.GET("/project/:projectId", ch.GetProjectComments)
 		comments.GET("/thread/:threadId", ch.GetThread)
 		comments.GET("/:id", ch.GetComment)
 		comments.PUT("/:id", ch.UpdateComment)
 		comments.DELETE("/:id", ch.DeleteComment)
 		comments.POST("/:id/resolve", ch.ResolveThread)
 		comments.POST("/:id/unresolve", ch.UnresolveThread)
+		comments.POST("/:id/apply", ch.ApplySuggestion)
+		comments.PUT("/:id/assign", ch.AssignThread)
+		comments.PUT("/:id/review", ch.SetReviewState)
 		comments.POST("/:id/react", ch.AddReaction)
 		comments.DELETE("/:id/react", ch.RemoveReaction)
 	}
@@ -206,20 +227,38 @@ func (ch *CommentsHandler) CreateComment(c *gin.Context) {
 		threadID = parentComment.ThreadID
 	}
 
+	// A suggested change remembers the lines it replaces
+	var suggestionBase string
+	if req.Suggestion != nil {
+		base, ok := commentRangeLines(file.Content, req.StartLine, req.EndLine)
+		if !ok {
+			c.JSON(http.StatusBadRequest, StandardResponse{
+				Success: false,
+				Error:   "Suggested change range is outside the file",
+				Code:    "INVALID_LINE_RANGE",
+			})
+			return
+		}
+		suggestionBase = base
+	}
+
 	// Create the comment
 	comment := models.CodeComment{
-		FileID:      req.FileID,
-		ProjectID:   req.ProjectID,
-		StartLine:   req.StartLine,
-		EndLine:     req.EndLine,
-		StartColumn: req.StartColumn,
-		EndColumn:   req.EndColumn,
-		Content:     req.Content,
-		ParentID:    req.ParentID,
-		ThreadID:    threadID,
-		AuthorID:    userID,
-		AuthorName:  user.Username,
-		Reactions:   make(map[string][]uint),
+		FileID:         req.FileID,
+		ProjectID:      req.ProjectID,
+		StartLine:      req.StartLine,
+		EndLine:        req.EndLine,
+		StartColumn:    req.StartColumn,
+		EndColumn:      req.EndColumn,
+		Content:        req.Content,
+		ParentID:       req.ParentID,
+		ThreadID:       threadID,
+		AuthorID:       userID,
+		AuthorName:     user.Username,
+		Reactions:      make(map[string][]uint),
+		Suggestion:     req.Suggestion,
+		SuggestionBase: suggestionBase,
+		Mentions:       ch.resolveMentions(req.Content, file.ProjectID, userID),
 	}
 
 	if err := ch.DB.Create(&comment).Error; err != nil {
@@ -231,6 +270,26 @@ func (ch *CommentsHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
+	action := "commented"
+	if req.ParentID != nil {
+		action = "replied"
+	}
+	if req.Suggestion != nil {
+		action = "suggested"
+	}
+	recordActivity(c, ch.Activity, &activity.Event{
+		ProjectID: file.ProjectID,
+		ActorID:   userID,
+		ActorName: user.Username,
+		Kind:      activity.KindComment,
+		Action:    action,
+		Target:    file.Path,
+		Summary:   fmt.Sprintf("%s on %s:%d", action, file.Path, req.StartLine),
+		Ref:       fmt.Sprintf("comment:%d", comment.ID),
+		Metadata:  map[string]any{"comment_id": comment.ID, "thread_id": threadID},
+	})
+	ch.notifyMentions(c, &comment, nil)
+
 	c.JSON(http.StatusCreated, StandardResponse{
 		Success: true,
 		Message: "Comment created successfully",
@@ -306,6 +365,17 @@ func (ch *CommentsHandler) GetFileComments(c *gin.Context) {
 		query = query.Where("start_line <= ? AND end_line >= ?", lineNumber, lineNumber)
 	}
 
+	// Task filters: status, assigned, review, reviewer
+	query, err = applyThreadFilters(query, c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_FILTER",
+		})
+		return
+	}
+
 	var rootComments []models.CodeComment
 	if err := query.Order("start_line ASC, created_at ASC").Find(&rootComments).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
@@ -316,31 +386,8 @@ func (ch *CommentsHandler) GetFileComments(c *gin.Context) {
 		return
 	}
 
-	// Get all replies for these root comments
-	threadIDs := make([]string, len(rootComments))
-	for i, comment := range rootComments {
-		threadIDs[i] = comment.ThreadID
-	}
-
-	var allReplies []models.CodeComment
-	if len(threadIDs) > 0 {
-		ch.DB.Where("thread_id IN ? AND parent_id IS NOT NULL", threadIDs).
-			Order("created_at ASC").
-			Find(&allReplies)
-	}
-
-	// Group replies by thread ID
-	repliesByThread := make(map[string][]models.CodeComment)
-	for _, reply := range allReplies {
-		repliesByThread[reply.ThreadID] = append(repliesByThread[reply.ThreadID], reply)
-	}
-
-	// Build response
-	responses := make([]CommentResponse, len(rootComments))
-	for i, comment := range rootComments {
-		replies := repliesByThread[comment.ThreadID]
-		responses[i] = *commentToResponse(&comment, replies)
-	}
+	// Attach replies to their root comments
+	responses := ch.threadResponses(rootComments)
 
 	c.JSON(http.StatusOK, StandardResponse{
 		Success: true,
@@ -588,8 +635,11 @@ func (ch *CommentsHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
-	// Update the comment
-	if err := ch.DB.Model(&comment).Update("content", req.Content).Error; err != nil {
+	// Update the comment; only newly mentioned users are notified
+	previousMentions := comment.Mentions
+	comment.Content = req.Content
+	comment.Mentions = ch.resolveMentions(req.Content, comment.ProjectID, userID)
+	if err := ch.DB.Model(&comment).Select("content", "mentions", "updated_at").Updates(&comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
 			Error:   "Failed to update comment",
@@ -600,6 +650,7 @@ func (ch *CommentsHandler) UpdateComment(c *gin.Context) {
 
 	// Refresh the comment
 	ch.DB.First(&comment, uint(commentID))
+	ch.notifyMentions(c, &comment, previousMentions)
 
 	c.JSON(http.StatusOK, StandardResponse{
 		Success: true,
@@ -1047,25 +1098,32 @@ func (ch *CommentsHandler) RemoveReaction(c *gin.Context) {
 // commentToResponse converts a CodeComment model to a CommentResponse
 func commentToResponse(comment *models.CodeComment, replies []models.CodeComment) *CommentResponse {
 	response := &CommentResponse{
-		ID:           comment.ID,
-		FileID:       comment.FileID,
-		ProjectID:    comment.ProjectID,
-		StartLine:    comment.StartLine,
-		EndLine:      comment.EndLine,
-		StartColumn:  comment.StartColumn,
-		EndColumn:    comment.EndColumn,
-		Content:      comment.Content,
-		ParentID:     comment.ParentID,
-		ThreadID:     comment.ThreadID,
-		AuthorID:     comment.AuthorID,
-		AuthorName:   comment.AuthorName,
-		IsResolved:   comment.IsResolved,
-		ResolvedAt:   comment.ResolvedAt,
-		ResolvedByID: comment.ResolvedByID,
-		Reactions:    comment.Reactions,
-		ReplyCount:   len(replies),
-		CreatedAt:    comment.CreatedAt,
-		UpdatedAt:    comment.UpdatedAt,
+		ID:                    comment.ID,
+		FileID:                comment.FileID,
+		ProjectID:             comment.ProjectID,
+		StartLine:             comment.StartLine,
+		EndLine:               comment.EndLine,
+		StartColumn:           comment.StartColumn,
+		EndColumn:             comment.EndColumn,
+		Content:               comment.Content,
+		ParentID:              comment.ParentID,
+		ThreadID:              comment.ThreadID,
+		AuthorID:              comment.AuthorID,
+		AuthorName:            comment.AuthorName,
+		IsResolved:            comment.IsResolved,
+		ResolvedAt:            comment.ResolvedAt,
+		ResolvedByID:          comment.ResolvedByID,
+		Reactions:             comment.Reactions,
+		Suggestion:            comment.Suggestion,
+		SuggestionAppliedAt:   comment.SuggestionAppliedAt,
+		SuggestionAppliedByID: comment.SuggestionAppliedByID,
+		AssigneeID:            comment.AssigneeID,
+		ReviewState:           comment.ReviewState,
+		ReviewerID:            comment.ReviewerID,
+		Mentions:              comment.Mentions,
+		ReplyCount:            len(replies),
+		CreatedAt:             comment.CreatedAt,
+		UpdatedAt:             comment.UpdatedAt,
 	}
 
 	if len(replies) > 0 {
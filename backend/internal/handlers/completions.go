@@ -4,6 +4,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -39,7 +40,7 @@ func (h *CompletionsHandler) GetInlineCompletion(c *gin.Context) {
 		return
 	}
 
-	item, err := h.service.GetInlineCompletion(c.Request.Context(), userID, &req)
+	response, err := h.service.GetCompletions(c.Request.Context(), userID, &req)
 	if err != nil {
 		if strings.Contains(err.Error(), "INSUFFICIENT_CREDITS") {
 			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient credits", "code": "INSUFFICIENT_CREDITS"})
@@ -49,10 +50,17 @@ func (h *CompletionsHandler) GetInlineCompletion(c *gin.Context) {
 		return
 	}
 
-	if item == nil {
+	// A newer keystroke from the same editor replaced this request
+	if response.Superseded {
+		c.JSON(http.StatusOK, gin.H{"completion": nil, "superseded": true})
+		return
+	}
+
+	if len(response.Completions) == 0 {
 		c.JSON(http.StatusOK, gin.H{"completion": nil})
 		return
 	}
+	item := &response.Completions[0]
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
@@ -110,11 +118,138 @@ func (h *CompletionsHandler) AcceptCompletion(c *gin.Context) {
 		return
 	}
 
-	h.service.AcceptCompletion(userID, req.CompletionID, req.Accepted)
+	if err := h.service.AcceptCompletion(c.Request.Context(), userID, req.CompletionID, req.Accepted); err != nil {
+		respondCompletionEventError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// RecordCompletionEvent records whether a shown completion was accepted,
+// partially accepted or rejected
+// POST /api/v1/completions/events
+func (h *CompletionsHandler) RecordCompletionEvent(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req completions.CompletionEventInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if err := h.service.RecordEvent(c.Request.Context(), userID, req); err != nil {
+		respondCompletionEventError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func respondCompletionEventError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, completions.ErrUnknownCompletion):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, completions.ErrInvalidOutcome):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record completion event"})
+	}
+}
+
+// GetCompletionAnalytics returns the user's acceptance rates per model and language
+// GET /api/v1/completions/analytics
+func (h *CompletionsHandler) GetCompletionAnalytics(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	days := completions.DefaultAnalyticsDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > completions.MaxAnalyticsDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and " + strconv.Itoa(completions.MaxAnalyticsDays)})
+			return
+		}
+		days = parsed
+	}
+
+	analytics, err := h.service.GetAcceptanceAnalytics(c.Request.Context(), userID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load completion analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    analytics,
+	})
+}
+
+// GetCompletionSettings returns the user's completion routing settings and
+// the measured latency of the fast models
+// GET /api/v1/completions/settings
+func (h *CompletionsHandler) GetCompletionSettings(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	settings, err := h.service.GetSettings(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load completion settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"data":        settings,
+		"fast_models": h.service.FastModelStatuses(),
+	})
+}
+
+// UpdateCompletionSettings changes the user's completion mode, fast model
+// and latency target
+// PUT /api/v1/completions/settings
+func (h *CompletionsHandler) UpdateCompletionSettings(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req completions.CompletionSettingsUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	settings, err := h.service.UpdateSettings(c.Request.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, completions.ErrInvalidMode),
+			errors.Is(err, completions.ErrInvalidLatencyTarget),
+			errors.Is(err, completions.ErrInvalidFastModel):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save completion settings"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    settings,
+	})
+}
+
 // GetCompletionStats returns completion metrics
 // GET /api/v1/completions/stats
 func (h *CompletionsHandler) GetCompletionStats(c *gin.Context) {
@@ -149,8 +284,9 @@ func (h *CompletionsHandler) RegisterCompletionRoutes(rg *gin.RouterGroup, meter
 		metered.POST("", h.GetCompletions)
 		metered.POST("/inline", h.GetInlineCompletion)
 		completionRoutes.POST("/accept", h.AcceptCompletion)
+		completionRoutes.POST("/events", h.RecordCompletionEvent)
+		completionRoutes.GET("/analytics", h.GetCompletionAnalytics)
+		completionRoutes.GET("/settings", h.GetCompletionSettings)
+		completionRoutes.PUT("/settings", h.UpdateCompletionSettings)
 	}
 }
-
-// Helper to suppress unused import warning
-var _ = strconv.Itoa
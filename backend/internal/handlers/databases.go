@@ -109,6 +109,17 @@ func (h *DatabaseHandler) CreateDatabase(c *gin.Context) {
 		return
 	}
 
+	if req.Type == database.DatabaseTypeRedis {
+		if err := database.ValidateRedisName(req.Name); err != nil {
+			c.JSON(http.StatusBadRequest, StandardResponse{
+				Success: false,
+				Error:   "Redis database names cannot contain ':'",
+				Code:    "INVALID_DATABASE_NAME",
+			})
+			return
+		}
+	}
+
 	// Check if a database with this name already exists for the project
 	var existingCount int64
 	h.DB.Model(&database.ManagedDatabase{}).
@@ -791,5 +802,13 @@ func (h *DatabaseHandler) RegisterDatabaseRoutes(rg *gin.RouterGroup) {
 		databases.GET("/:dbId/tables", h.GetTables)
 		databases.GET("/:dbId/tables/:table/schema", h.GetTableSchema)
 		databases.GET("/:dbId/metrics", h.GetMetrics)
+
+		// Redis browser
+		databases.GET("/:dbId/redis/keys", h.GetRedisKeys)
+		databases.GET("/:dbId/redis/queues", h.GetRedisQueues)
+		databases.GET("/:dbId/redis/channels", h.GetRedisChannels)
+		databases.GET("/:dbId/redis/channels/peek", h.PeekRedisChannel)
+		databases.GET("/:dbId/redis/memory", h.GetRedisMemory)
+		databases.POST("/:dbId/redis/flush", h.FlushRedis)
 	}
 }
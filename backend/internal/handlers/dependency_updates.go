@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/depupdates"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DependencyUpdateHandler serves the per-project dependency update agent:
+// its policy, manual runs, run results and applying verified change sets.
+type DependencyUpdateHandler struct {
+	service *depupdates.Service
+}
+
+// NewDependencyUpdateHandler creates a new DependencyUpdateHandler.
+func NewDependencyUpdateHandler(service *depupdates.Service) *DependencyUpdateHandler {
+	return &DependencyUpdateHandler{service: service}
+}
+
+// GetDependencyUpdatePolicy returns the project's policy and recent runs.
+// GET /projects/:id/dependency-updates
+func (h *DependencyUpdateHandler) GetDependencyUpdatePolicy(c *gin.Context) {
+	userID, projectID, ok := h.parseDependencyUpdateRequest(c)
+	if !ok {
+		return
+	}
+
+	policy, err := h.service.GetPolicy(c.Request.Context(), userID, projectID)
+	if err != nil {
+		h.respondDependencyUpdateError(c, err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runs, err := h.service.ListRuns(c.Request.Context(), userID, projectID, limit)
+	if err != nil {
+		h.respondDependencyUpdateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"policy": policy, "runs": runs}})
+}
+
+// UpdateDependencyUpdatePolicy creates or patches the project's policy.
+// PUT /projects/:id/dependency-updates
+func (h *DependencyUpdateHandler) UpdateDependencyUpdatePolicy(c *gin.Context) {
+	userID, projectID, ok := h.parseDependencyUpdateRequest(c)
+	if !ok {
+		return
+	}
+
+	var in depupdates.PolicyInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	policy, err := h.service.UpdatePolicy(c.Request.Context(), userID, projectID, in)
+	if err != nil {
+		h.respondDependencyUpdateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"policy": policy}})
+}
+
+// RunDependencyUpdates starts a run now.
+// POST /projects/:id/dependency-updates/run
+func (h *DependencyUpdateHandler) RunDependencyUpdates(c *gin.Context) {
+	userID, projectID, ok := h.parseDependencyUpdateRequest(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.service.RunNow(c.Request.Context(), userID, projectID)
+	if err != nil {
+		h.respondDependencyUpdateError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": gin.H{"run": run}})
+}
+
+// GetDependencyUpdateRun returns one run with its change set and
+// verification output.
+// GET /projects/:id/dependency-updates/runs/:runId
+func (h *DependencyUpdateHandler) GetDependencyUpdateRun(c *gin.Context) {
+	userID, projectID, runID, ok := h.parseDependencyUpdateRunRequest(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.service.GetRun(c.Request.Context(), userID, projectID, runID)
+	if err != nil {
+		h.respondDependencyUpdateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"run": run}})
+}
+
+// ApplyDependencyUpdateRun writes a verified change set into the project.
+// POST /projects/:id/dependency-updates/runs/:runId/apply
+func (h *DependencyUpdateHandler) ApplyDependencyUpdateRun(c *gin.Context) {
+	userID, projectID, runID, ok := h.parseDependencyUpdateRunRequest(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.service.Apply(c.Request.Context(), userID, projectID, runID)
+	if err != nil {
+		h.respondDependencyUpdateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"run": run}})
+}
+
+// RegisterRoutes registers the dependency update endpoints.
+func (h *DependencyUpdateHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	dg := rg.Group("/projects/:id/dependency-updates")
+	{
+		dg.GET("", h.GetDependencyUpdatePolicy)
+		dg.PUT("", h.UpdateDependencyUpdatePolicy)
+		dg.POST("/run", h.RunDependencyUpdates)
+		dg.GET("/runs/:runId", h.GetDependencyUpdateRun)
+		dg.POST("/runs/:runId/apply", h.ApplyDependencyUpdateRun)
+	}
+}
+
+func (h *DependencyUpdateHandler) parseDependencyUpdateRequest(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project id"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func (h *DependencyUpdateHandler) parseDependencyUpdateRunRequest(c *gin.Context) (uint, uint, uint, bool) {
+	userID, projectID, ok := h.parseDependencyUpdateRequest(c)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid run id"})
+		return 0, 0, 0, false
+	}
+	return userID, projectID, uint(runID), true
+}
+
+func (h *DependencyUpdateHandler) respondDependencyUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, depupdates.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, depupdates.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, depupdates.ErrAlreadyRunning), errors.Is(err, depupdates.ErrNotApplicable), errors.Is(err, depupdates.ErrStale):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Dependency update operation failed"})
+	}
+}
+
+// OpenFilesPullRequest commits files to a new branch off the connected
+// branch and opens a pull request. It returns "" when the project has no
+// connected GitHub repository or the owner has no stored token.
+func (h *GitHandler) OpenFilesPullRequest(ctx context.Context, userID, projectID uint, branch, title, body string, files map[string]string) (string, error) {
+	repo, err := h.gitService.GetRepository(ctx, projectID)
+	if err != nil || repo.Provider != "github" {
+		return "", nil
+	}
+	token := h.getGitToken(userID, projectID)
+	if token == "" {
+		return "", nil
+	}
+	if _, err := h.gitService.CreateBranch(ctx, projectID, branch, repo.Branch, token); err != nil {
+		return "", err
+	}
+	if _, err := h.gitService.CommitContentsToBranch(ctx, projectID, branch, title, files, token); err != nil {
+		return "", err
+	}
+	pr, err := h.gitService.CreatePullRequest(ctx, projectID, title, body, branch, repo.Branch, token)
+	if err != nil {
+		return "", err
+	}
+	return pr.URL, nil
+}
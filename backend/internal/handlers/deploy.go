@@ -2,10 +2,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"apex-build/internal/deploy"
+	"apex-build/internal/pagination"
+	"apex-build/internal/payments"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -242,6 +245,49 @@ func (h *DeployHandler) Redeploy(c *gin.Context) {
 	})
 }
 
+// GetDeploymentProvenance returns the SLSA provenance attestation for a
+// deployment. ?format=dsse returns the bare DSSE envelope for verifiers.
+// GET /api/v1/deploy/:id/provenance
+func (h *DeployHandler) GetDeploymentProvenance(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	deploymentID := c.Param("id")
+
+	deployment, err := h.service.GetDeploymentStatus(deploymentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return
+	}
+	if deployment.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	provenance, err := h.service.GetProvenance(deploymentID)
+	if err != nil {
+		if errors.Is(err, deploy.ErrProvenanceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No provenance recorded; the deployment did not reach the build step"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "dsse" {
+		c.JSON(http.StatusOK, provenance.Envelope)
+		return
+	}
+
+	publicKey, keyID := h.service.ProvenancePublicKey()
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"provenance": provenance,
+		"signed":     len(provenance.Envelope.Signatures) > 0,
+		"verified":   h.service.VerifyProvenance(provenance),
+		"public_key": publicKey,
+		"key_id":     keyID,
+	})
+}
+
 // GetProjectDeployments returns deployment history for a project
 // GET /api/v1/deploy/projects/:projectId/history
 func (h *DeployHandler) GetProjectDeployments(c *gin.Context) {
@@ -266,10 +312,19 @@ func (h *DeployHandler) GetProjectDeployments(c *gin.Context) {
 	}
 
 	// Get pagination params
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	list, err := pagination.Parse(c, deploy.DeploymentListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	deployments, total, err := h.service.GetDeploymentHistory(uint(projectID), page, limit)
+	deployments, pageInfo, total, err := h.service.ListDeploymentHistory(uint(projectID), list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	selected, err := pagination.SelectFields(deployments, list.Fields)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -277,10 +332,11 @@ func (h *DeployHandler) GetProjectDeployments(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":     true,
-		"deployments": deployments,
+		"deployments": selected,
 		"total":       total,
-		"limit":       limit,
+		"limit":       list.Limit,
 		"page":        page,
+		"page_info":   pageInfo,
 	})
 }
 
@@ -329,3 +385,54 @@ func (h *DeployHandler) GetLatestDeployment(c *gin.Context) {
 		"deployment": deployments[0],
 	})
 }
+
+// EstimateDeploymentCost projects the monthly cost of hosting the project on
+// native hosting and each provider, so users can pick the cheapest viable
+// target before deploying
+// GET /api/v1/deploy/projects/:projectId/estimate?monthly_views=&always_on=
+func (h *DeployHandler) EstimateDeploymentCost(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var project models.Project
+	if err := h.db.Select("id", "owner_id").First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if project.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	opts := deploy.EstimateOptions{AlwaysOn: c.Query("always_on") == "true"}
+	if raw := c.Query("monthly_views"); raw != "" {
+		views, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || views < 0 || views > deploy.MaxMonthlyPageViews {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "monthly_views must be between 0 and 1000000000"})
+			return
+		}
+		opts.MonthlyPageViews = views
+	}
+	// Native hosting comes with paid backend plans; otherwise it costs the
+	// cheapest such plan
+	opts.NativeIncluded = hasPaidBackendPlan(c, h.db, userID)
+	if plan := payments.GetPlanByType(payments.PlanBuilder); plan != nil {
+		opts.NativePlanUSD = float64(plan.MonthlyPriceCents) / 100
+		opts.NativePlanName = plan.Name + " plan"
+	}
+
+	estimate, err := h.service.EstimateCost(project.ID, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to estimate deployment cost"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"estimate": estimate,
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/dockerize"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DockerizeHandler generates production Dockerfiles for projects and verifies
+// them with an image build in the sandbox
+type DockerizeHandler struct {
+	db      *gorm.DB
+	service *dockerize.Service
+	access  collaboration.AccessResolver
+}
+
+// NewDockerizeHandler creates a new DockerizeHandler
+func NewDockerizeHandler(db *gorm.DB, service *dockerize.Service) *DockerizeHandler {
+	return &DockerizeHandler{db: db, service: service}
+}
+
+// SetAccessResolver lets collaborators and organization members in,
+// according to their permission on the project
+func (h *DockerizeHandler) SetAccessResolver(access collaboration.AccessResolver) {
+	h.access = access
+}
+
+// RegisterRoutes registers the dockerize routes on the protected group
+func (h *DockerizeHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/dockerize", h.GetDockerize)
+	rg.POST("/projects/:id/dockerize", h.StartDockerize)
+}
+
+// GetDockerize previews the Dockerfile that would be generated and returns
+// the latest verification run
+func (h *DockerizeHandler) GetDockerize(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, nil)
+	if !ok {
+		return
+	}
+
+	preview, err := h.service.Preview(project.ID)
+	previewError := ""
+	if err != nil {
+		if !errors.Is(err, dockerize.ErrUnsupported) && !errors.Is(err, dockerize.ErrNoFiles) {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to generate Dockerfile"})
+			return
+		}
+		previewError = err.Error()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"preview":          preview,
+		"preview_error":    previewError,
+		"run":              h.service.LatestRun(project.ID),
+		"verify_available": h.service.Available(),
+	})
+}
+
+// StartDockerize generates a Dockerfile and starts verifying it with an image
+// build. The Dockerfile is stored in the project only if the build succeeds;
+// poll GetDockerize for the result.
+func (h *DockerizeHandler) StartDockerize(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	if !requirePaidBackendPlan(c, h.db, project.OwnerID, "Dockerfile builds") {
+		return
+	}
+
+	var req struct {
+		Overwrite bool `json:"overwrite"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+			return
+		}
+	}
+
+	run, err := h.service.Start(project, project.OwnerID, dockerize.Options{Overwrite: req.Overwrite})
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := err.Error()
+		switch {
+		case errors.Is(err, dockerize.ErrUnsupported), errors.Is(err, dockerize.ErrNoFiles):
+			status = http.StatusBadRequest
+		case errors.Is(err, dockerize.ErrDockerfileExists), errors.Is(err, dockerize.ErrBuildInProgress):
+			status = http.StatusConflict
+		case errors.Is(err, dockerize.ErrBuilderUnavailable):
+			status = http.StatusServiceUnavailable
+		default:
+			message = "Failed to start Dockerfile build"
+		}
+		c.JSON(status, gin.H{"success": false, "error": message})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "run": run})
+}
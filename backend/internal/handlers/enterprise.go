@@ -4,11 +4,18 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"apex-build/internal/auth"
+	"apex-build/internal/codestandards"
 	"apex-build/internal/enterprise"
+	"apex-build/internal/instructions"
 	"apex-build/internal/middleware"
+	"apex-build/internal/pagination"
+	"apex-build/internal/secrets"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -21,6 +28,13 @@ type EnterpriseHandler struct {
 	scimService  *enterprise.SCIMService
 	auditService *enterprise.AuditService
 	rbacService  *enterprise.RBACService
+
+	codingStandards  *codestandards.Service            // optional; wired via SetCodingStandardsService
+	orgKeys          *secrets.OrgKeyring               // optional; wired via SetOrgKeyring
+	sessions         *auth.AuthService                 // optional; wired via SetAuthService
+	networkPolicies  *enterprise.NetworkPolicyService  // optional; wired via SetNetworkPolicyService
+	identities       *enterprise.IdentityService       // optional; wired via SetIdentityService
+	aiRegionPolicies *enterprise.AIRegionPolicyService // optional; wired via SetAIRegionPolicyService
 }
 
 // NewEnterpriseHandler creates a new enterprise handler
@@ -40,6 +54,11 @@ func NewEnterpriseHandler(
 	}
 }
 
+// SetCodingStandardsService enables the organization coding standards endpoints.
+func (h *EnterpriseHandler) SetCodingStandardsService(service *codestandards.Service) {
+	h.codingStandards = service
+}
+
 // InitiateSSO starts SAML SSO flow
 // GET /api/v1/enterprise/sso/initiate
 func (h *EnterpriseHandler) InitiateSSO(c *gin.Context) {
@@ -59,6 +78,9 @@ func (h *EnterpriseHandler) InitiateSSO(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "SSO not enabled for this organization"})
 		return
 	}
+	if !h.ssoNetworkAllowed(c, org) {
+		return
+	}
 
 	relayState := c.Query("relay_state")
 	redirectURL, err := h.samlService.GenerateAuthnRequest(org, relayState)
@@ -101,12 +123,22 @@ func (h *EnterpriseHandler) HandleSAMLCallback(c *gin.Context) {
 		return
 	}
 
+	if !h.ssoNetworkAllowed(c, org) {
+		return
+	}
+
 	assertion, err := h.samlService.ProcessSAMLResponse(org, samlResponse)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "SSO authentication failed: " + err.Error()})
 		return
 	}
 
+	// With identity linking available the assertion signs the user in
+	if h.identities != nil && h.sessions != nil {
+		h.signInSSOUser(c, org, assertion)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
 		"assertion": assertion,
@@ -243,23 +275,34 @@ func (h *EnterpriseHandler) GetAuditLogs(c *gin.Context) {
 		return
 	}
 
-	action := c.Query("action")
-	category := c.Query("category")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	list, err := pagination.Parse(c, enterprise.AuditLogListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	logs, total, err := h.auditService.GetAuditLogs(uint(orgID), action, category, page, pageSize)
+	logs, pageInfo, total, err := h.auditService.ListAuditLogs(uint(orgID), c.Query("action"), c.Query("category"), list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	selected, err := pagination.SelectFields(logs, list.Fields)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	page := 1
+	if list.Limit > 0 {
+		page = list.Offset/list.Limit + 1
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
-		"audit_logs": logs,
+		"audit_logs": selected,
 		"total":      total,
 		"page":       page,
-		"page_size":  pageSize,
+		"page_size":  list.Limit,
+		"page_info":  pageInfo,
 	})
 }
 
@@ -295,6 +338,269 @@ func (h *EnterpriseHandler) GetRoles(c *gin.Context) {
 	})
 }
 
+// UpdateRetention configures an organization's data retention windows and
+// legal hold
+// PUT /api/v1/enterprise/organizations/:id/retention
+func (h *EnterpriseHandler) UpdateRetention(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return
+	}
+
+	org, err := h.rbacService.GetOrganization(uint(orgID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req struct {
+		AuditLogRetentionDays *int    `json:"audit_log_retention_days"`
+		DataRetentionDays     *int    `json:"data_retention_days"`
+		LegalHold             *bool   `json:"legal_hold"`
+		LegalHoldReason       *string `json:"legal_hold_reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.AuditLogRetentionDays != nil {
+		if *req.AuditLogRetentionDays < 0 || *req.AuditLogRetentionDays > 3650 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "audit_log_retention_days must be between 0 and 3650"})
+			return
+		}
+		updates["audit_log_retention_days"] = *req.AuditLogRetentionDays
+	}
+	if req.DataRetentionDays != nil {
+		if *req.DataRetentionDays < 0 || *req.DataRetentionDays > 3650 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "data_retention_days must be between 0 and 3650"})
+			return
+		}
+		updates["data_retention_days"] = *req.DataRetentionDays
+	}
+	if req.LegalHold != nil && *req.LegalHold != org.LegalHold {
+		updates["legal_hold"] = *req.LegalHold
+		if *req.LegalHold {
+			updates["legal_hold_since"] = time.Now().UTC()
+		} else {
+			updates["legal_hold_since"] = nil
+			updates["legal_hold_reason"] = ""
+		}
+	}
+	if req.LegalHoldReason != nil && (org.LegalHold || (req.LegalHold != nil && *req.LegalHold)) {
+		updates["legal_hold_reason"] = *req.LegalHoldReason
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No retention settings provided"})
+		return
+	}
+
+	if err := h.db.Model(org).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org.ID,
+		UserID:         &userID,
+		Action:         "retention_updated",
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(uint64(org.ID), 10),
+		ResourceName:   org.Name,
+		Category:       "data",
+		Description:    "Data retention settings updated",
+		NewValue:       updates,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"organization": org,
+	})
+}
+
+// UpdateAIInstructions sets the instructions added to AI prompts on the
+// organization's projects, and whether project apex.md files also apply
+// PUT /api/v1/enterprise/organizations/:id/ai-instructions
+func (h *EnterpriseHandler) UpdateAIInstructions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return
+	}
+
+	org, err := h.rbacService.GetOrganization(uint(orgID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req struct {
+		Instructions             *string `json:"instructions"`
+		AllowProjectInstructions *bool   `json:"allow_project_instructions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Instructions != nil {
+		if len(*req.Instructions) > instructions.MaxOrganizationBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("instructions must be at most %d bytes", instructions.MaxOrganizationBytes)})
+			return
+		}
+		updates["ai_instructions"] = *req.Instructions
+	}
+	if req.AllowProjectInstructions != nil {
+		updates["allow_project_ai_instructions"] = *req.AllowProjectInstructions
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No instruction settings provided"})
+		return
+	}
+
+	if err := h.db.Model(org).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org.ID,
+		UserID:         &userID,
+		Action:         "ai_instructions_updated",
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(uint64(org.ID), 10),
+		ResourceName:   org.Name,
+		Category:       "system",
+		Description:    "Organization AI instructions updated",
+		NewValue:       updates,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"organization": org,
+	})
+}
+
+// GetCodingStandards returns the coding standards applied to builds by the
+// organization's members
+// GET /api/v1/enterprise/organizations/:id/coding-standards
+func (h *EnterpriseHandler) GetCodingStandards(c *gin.Context) {
+	orgID, _, ok := h.codingStandardsRequest(c, "read")
+	if !ok {
+		return
+	}
+
+	standards, err := h.codingStandards.Get(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"coding_standards": standards,
+		"prompt_preview":   codestandards.PromptContext(&standards.Policy),
+	})
+}
+
+// UpdateCodingStandards replaces the organization's coding standards: a style
+// guide, banned libraries, and required or forbidden code patterns. They are
+// added to agent prompts for members' builds and checked during review.
+// PUT /api/v1/enterprise/organizations/:id/coding-standards
+func (h *EnterpriseHandler) UpdateCodingStandards(c *gin.Context) {
+	orgID, userID, ok := h.codingStandardsRequest(c, "manage")
+	if !ok {
+		return
+	}
+
+	org, err := h.rbacService.GetOrganization(orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var policy codestandards.Policy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	standards, err := h.codingStandards.Save(c.Request.Context(), orgID, userID, policy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org.ID,
+		UserID:         &userID,
+		Action:         "coding_standards_updated",
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(uint64(org.ID), 10),
+		ResourceName:   org.Name,
+		Category:       "system",
+		Description:    "Organization coding standards updated",
+		NewValue: map[string]interface{}{
+			"banned_libraries":   standards.Policy.BannedLibraries,
+			"required_patterns":  len(standards.Policy.RequiredPatterns),
+			"forbidden_patterns": len(standards.Policy.ForbiddenPatterns),
+			"style_guide_bytes":  len(standards.Policy.StyleGuide),
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"coding_standards": standards,
+		"prompt_preview":   codestandards.PromptContext(&standards.Policy),
+	})
+}
+
+func (h *EnterpriseHandler) codingStandardsRequest(c *gin.Context, action string) (uint, uint, bool) {
+	if h.codingStandards == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Coding standards are not available"})
+		return 0, 0, false
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", action) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return 0, 0, false
+	}
+	return uint(orgID), userID, true
+}
+
 // RegisterEnterpriseRoutes registers all enterprise routes
 func (h *EnterpriseHandler) RegisterEnterpriseRoutes(protected *gin.RouterGroup, public *gin.RouterGroup) {
 	// Public SSO endpoints (no auth required for SSO flow)
@@ -311,10 +617,35 @@ func (h *EnterpriseHandler) RegisterEnterpriseRoutes(protected *gin.RouterGroup,
 		// Organizations
 		ent.GET("/organizations", h.GetOrganizations)
 		ent.GET("/organizations/:id", h.GetOrganization)
+		ent.DELETE("/organizations/:id", h.DeleteOrganization)
 		ent.POST("/organizations/:id/sso", h.ConfigureSSO)
 		ent.GET("/organizations/:id/audit-logs", h.GetAuditLogs)
 		ent.GET("/organizations/:id/roles", h.GetRoles)
-	}
+		ent.PUT("/organizations/:id/retention", h.UpdateRetention)
+		ent.PUT("/organizations/:id/ai-instructions", h.UpdateAIInstructions)
+		ent.GET("/organizations/:id/coding-standards", h.GetCodingStandards)
+		ent.PUT("/organizations/:id/coding-standards", h.UpdateCodingStandards)
+		ent.GET("/organizations/:id/encryption", h.GetEncryptionKeys)
+		ent.PUT("/organizations/:id/encryption", h.ConfigureEncryptionKey)
+		ent.POST("/organizations/:id/encryption/rotate", h.RotateEncryptionKey)
+		ent.POST("/organizations/:id/logout", h.ForceLogoutMembers)
+		ent.POST("/organizations/:id/members/:userId/logout", h.ForceLogoutMembers)
+		ent.GET("/organizations/:id/network-policy", h.GetNetworkPolicy)
+		ent.PUT("/organizations/:id/network-policy", h.UpdateNetworkPolicy)
+		ent.GET("/organizations/:id/ai-region-policy", h.GetAIRegionPolicy)
+		ent.PUT("/organizations/:id/ai-region-policy", h.UpdateAIRegionPolicy)
+		ent.POST("/organizations/:id/identity-migration", h.RunIdentityMigration)
+		ent.POST("/organizations/:id/members/merge", h.MergeMemberAccounts)
+		ent.GET("/organizations/:id/domains", h.ListOrganizationDomains)
+		ent.POST("/organizations/:id/domains", h.AddOrganizationDomain)
+		ent.POST("/organizations/:id/domains/:domainId/verify", h.VerifyOrganizationDomain)
+		ent.DELETE("/organizations/:id/domains/:domainId", h.DeleteOrganizationDomain)
+	}
+
+	// Linked sign-in identities for the caller
+	protected.GET("/user/identities", h.ListIdentities)
+	protected.POST("/user/identities/link", h.LinkPendingIdentity)
+	protected.DELETE("/user/identities/:identityId", h.UnlinkIdentity)
 
 	// SCIM endpoints (authenticated with SCIM token, not JWT)
 	if h.scimService != nil {
@@ -0,0 +1,98 @@
+// APEX.BUILD Enterprise AI Region Policy Handlers
+// Organization data residency rules for AI requests
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/enterprise"
+	"apex-build/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetAIRegionPolicyService enables organization AI region policies
+func (h *EnterpriseHandler) SetAIRegionPolicyService(service *enterprise.AIRegionPolicyService) {
+	h.aiRegionPolicies = service
+}
+
+func (h *EnterpriseHandler) aiRegionPolicyRequest(c *gin.Context) (uint, uint, bool) {
+	if h.aiRegionPolicies == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI region policies are not available"})
+		return 0, 0, false
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return 0, 0, false
+	}
+	return uint(orgID), userID, true
+}
+
+// GetAIRegionPolicy returns an organization's AI region policy
+// GET /api/v1/enterprise/organizations/:id/ai-region-policy
+func (h *EnterpriseHandler) GetAIRegionPolicy(c *gin.Context) {
+	orgID, _, ok := h.aiRegionPolicyRequest(c)
+	if !ok {
+		return
+	}
+
+	policy, err := h.aiRegionPolicies.Get(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"policy":            policy,
+		"available_regions": ai.KnownRegions,
+	})
+}
+
+// UpdateAIRegionPolicy replaces an organization's AI region policy
+// PUT /api/v1/enterprise/organizations/:id/ai-region-policy
+func (h *EnterpriseHandler) UpdateAIRegionPolicy(c *gin.Context) {
+	orgID, userID, ok := h.aiRegionPolicyRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled        bool     `json:"enabled"`
+		AllowedRegions []string `json:"allowed_regions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	saved, err := h.aiRegionPolicies.Save(orgID, userID, &enterprise.AIRegionPolicy{
+		Enabled:        req.Enabled,
+		AllowedRegions: req.AllowedRegions,
+	}, c.ClientIP(), c.Request.UserAgent())
+	switch {
+	case errors.Is(err, enterprise.ErrInvalidAIRegionPolicy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"policy":  saved,
+	})
+}
@@ -0,0 +1,247 @@
+// APEX.BUILD Enterprise Encryption Key Handlers
+// Per-organization data keys wrapped by a customer KMS key
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/middleware"
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SetOrgKeyring enables organization encryption key management and
+// crypto-shredding on organization deletion
+func (h *EnterpriseHandler) SetOrgKeyring(keyring *secrets.OrgKeyring) {
+	h.orgKeys = keyring
+}
+
+func (h *EnterpriseHandler) encryptionRequest(c *gin.Context, action string) (uint, uint, bool) {
+	if h.orgKeys == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Organization encryption keys are not available"})
+		return 0, 0, false
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", action) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return 0, 0, false
+	}
+	return uint(orgID), userID, true
+}
+
+// GetEncryptionKeys lists the versions of an organization's data key
+// GET /api/v1/enterprise/organizations/:id/encryption
+func (h *EnterpriseHandler) GetEncryptionKeys(c *gin.Context) {
+	orgID, _, ok := h.encryptionRequest(c, "read")
+	if !ok {
+		return
+	}
+
+	keys, err := h.orgKeys.Keys(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var active *secrets.OrgDataKey
+	for i := range keys {
+		if keys[i].Status == secrets.OrgKeyActive {
+			active = &keys[i]
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"enabled": active != nil,
+		"active":  active,
+		"keys":    keys,
+	})
+}
+
+// ConfigureEncryptionKey enables organization keys or moves them to another
+// KMS key. Either way a new data key is created and values re-encrypted.
+// PUT /api/v1/enterprise/organizations/:id/encryption
+func (h *EnterpriseHandler) ConfigureEncryptionKey(c *gin.Context) {
+	orgID, userID, ok := h.encryptionRequest(c, "manage")
+	if !ok {
+		return
+	}
+	var req struct {
+		Provider string `json:"provider" binding:"required"`
+		KMSKeyID string `json:"kms_key_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	h.rotateEncryptionKey(c, orgID, userID, req.Provider, req.KMSKeyID)
+}
+
+// RotateEncryptionKey replaces an organization's data key under its
+// current KMS key and re-encrypts values
+// POST /api/v1/enterprise/organizations/:id/encryption/rotate
+func (h *EnterpriseHandler) RotateEncryptionKey(c *gin.Context) {
+	orgID, userID, ok := h.encryptionRequest(c, "manage")
+	if !ok {
+		return
+	}
+	if _, err := h.orgKeys.ActiveKey(orgID); errors.Is(err, secrets.ErrOrgKeyNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization encryption keys are not enabled"})
+		return
+	}
+	// An empty provider keeps the current KMS key
+	h.rotateEncryptionKey(c, orgID, userID, "", "")
+}
+
+func (h *EnterpriseHandler) rotateEncryptionKey(c *gin.Context, orgID, userID uint, provider, keyID string) {
+	previous, err := h.orgKeys.ActiveKey(orgID)
+	if err != nil && !errors.Is(err, secrets.ErrOrgKeyNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, summary, err := h.orgKeys.Rotate(c.Request.Context(), orgID, userID, provider, keyID)
+	switch {
+	case errors.Is(err, secrets.ErrInvalidKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, secrets.ErrKMSUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	action, description := "encryption_key_rotated", "Organization data key rotated"
+	if previous == nil {
+		action, description = "encryption_key_enabled", "Organization encryption keys enabled"
+	}
+	newValue := map[string]interface{}{
+		"version":     key.Version,
+		"provider":    key.Provider,
+		"kms_key_id":  key.KMSKeyID,
+		"reencrypted": summary.Reencrypted,
+		"failed":      summary.Failed,
+	}
+	var oldValue map[string]interface{}
+	if previous != nil {
+		oldValue = map[string]interface{}{
+			"version":    previous.Version,
+			"provider":   previous.Provider,
+			"kms_key_id": previous.KMSKeyID,
+		}
+	}
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &orgID,
+		UserID:         &userID,
+		Action:         action,
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(uint64(orgID), 10),
+		Category:       "data",
+		Description:    description,
+		OldValue:       oldValue,
+		NewValue:       newValue,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"key":      key,
+		"rotation": summary,
+	})
+}
+
+// DeleteOrganization deletes an organization and crypto-shreds its data
+// keys, so values encrypted under them can never be read again. Its
+// projects stay with the members who own them.
+// DELETE /api/v1/enterprise/organizations/:id?confirm=<slug>
+func (h *EnterpriseHandler) DeleteOrganization(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return
+	}
+	org, err := h.rbacService.GetOrganization(uint(orgID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	if c.Query("confirm") != org.Slug {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pass the organization slug as confirm to delete it"})
+		return
+	}
+	if org.LegalHold {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization is under legal hold"})
+		return
+	}
+
+	// Shred first: if deletion then fails, the data is already unreadable,
+	// which is what the caller asked for
+	var shredded int64
+	if h.orgKeys != nil {
+		if shredded, err = h.orgKeys.Shred(org.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to destroy encryption keys: " + err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Project{}).Where("organization_id = ?", org.ID).
+			Updates(map[string]interface{}{"organization_id": nil, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", org.ID).Delete(&enterprise.Invitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", org.ID).Delete(&enterprise.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(org).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org.ID,
+		UserID:         &userID,
+		Action:         "organization_deleted",
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(uint64(org.ID), 10),
+		ResourceName:   org.Name,
+		Category:       "data",
+		Description:    "Organization deleted and encryption keys destroyed",
+		NewValue:       map[string]interface{}{"destroyed_keys": shredded},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"destroyed_keys": shredded,
+	})
+}
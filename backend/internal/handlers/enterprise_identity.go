@@ -0,0 +1,394 @@
+// APEX.BUILD Enterprise Identity Handlers
+// SSO sign-in with identity linking, linked identities and account merges
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/auth"
+	"apex-build/internal/enterprise"
+	"apex-build/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SetIdentityService enables SSO sign-in with identity linking, the linked
+// identities endpoints and account merges
+func (h *EnterpriseHandler) SetIdentityService(service *enterprise.IdentityService) {
+	h.identities = service
+}
+
+// signInSSOUser resolves the user behind a verified SAML assertion, linking
+// it to an existing account with the same email when the organization has
+// verified the email's domain, and starts a session. Any other match
+// returns a link token the account's owner accepts after signing in.
+func (h *EnterpriseHandler) signInSSOUser(c *gin.Context, org *enterprise.Organization, assertion *enterprise.SAMLAssertion) {
+	ext := enterprise.SAMLIdentity(org, assertion)
+	user, outcome, err := h.identities.Resolve(ext)
+	var linkRequired *enterprise.LinkRequiredError
+	switch {
+	case errors.As(err, &linkRequired):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           err.Error(),
+			"error_code":      "link_required",
+			"link_token":      linkRequired.Token,
+			"link_expires_at": linkRequired.ExpiresAt,
+		})
+		return
+	case errors.Is(err, enterprise.ErrUnverifiedEmail):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + "; sign in with your password and verify your email first", "error_code": "email_not_verified"})
+		return
+	case errors.Is(err, enterprise.ErrIdentityDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, enterprise.ErrInvalidIdentity):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "SSO sign-in failed"})
+		return
+	}
+	if err := h.identities.EnsureMembership(org, user.ID, ext, assertion.AttributeStatement.Attributes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add you to the organization: " + err.Error()})
+		return
+	}
+
+	tokens, err := h.sessions.GenerateTokensWithMetadata(user, auth.RequestMetadata(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+	auth.SetAccessTokenCookie(c, tokens.AccessToken)
+	auth.SetRefreshTokenCookie(c, tokens.RefreshToken)
+
+	userID := user.ID
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org.ID,
+		UserID:         &userID,
+		Username:       user.Username,
+		Email:          user.Email,
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		Action:         "sso_login",
+		ResourceType:   "user",
+		ResourceID:     strconv.FormatUint(uint64(user.ID), 10),
+		Category:       "authentication",
+		Description:    "Signed in with SAML SSO (" + outcome + ")",
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":                 true,
+		"message":                 "SSO authentication successful",
+		"identity_outcome":        outcome,
+		"session_strategy":        "cookie",
+		"access_token_expires_at": tokens.AccessTokenExpiresAt,
+		"token_type":              tokens.TokenType,
+		"user": gin.H{
+			"id":        user.ID,
+			"username":  user.Username,
+			"email":     user.Email,
+			"full_name": user.FullName,
+		},
+	})
+}
+
+// ListIdentities returns the SSO identities linked to the caller's account
+// GET /api/v1/user/identities
+func (h *EnterpriseHandler) ListIdentities(c *gin.Context) {
+	if h.identities == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Identity linking is not available"})
+		return
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	identities, err := h.identities.ListIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var passwordHash string
+	h.db.Table("users").Where("id = ?", userID).Select("password_hash").Scan(&passwordHash)
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"identities":   identities,
+		"has_password": passwordHash != "",
+	})
+}
+
+// LinkPendingIdentity links the SSO identity behind a link token from a
+// failed SSO sign-in to the caller's account
+// POST /api/v1/user/identities/link
+func (h *EnterpriseHandler) LinkPendingIdentity(c *gin.Context) {
+	if h.identities == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Identity linking is not available"})
+		return
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req struct {
+		LinkToken string `json:"link_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	identity, err := h.identities.LinkPending(userID, req.LinkToken)
+	switch {
+	case errors.Is(err, enterprise.ErrLinkExpired):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, enterprise.ErrInvalidIdentity):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "identity": identity})
+}
+
+// UnlinkIdentity removes one of the caller's linked identities
+// DELETE /api/v1/user/identities/:identityId
+func (h *EnterpriseHandler) UnlinkIdentity(c *gin.Context) {
+	if h.identities == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Identity linking is not available"})
+		return
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	identityID, err := strconv.ParseUint(c.Param("identityId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+	err = h.identities.UnlinkIdentity(userID, uint(identityID))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	case errors.Is(err, enterprise.ErrLastIdentity):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + "; set a password first"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *EnterpriseHandler) identityAdminRequest(c *gin.Context) (uint, uint, bool) {
+	if h.identities == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Identity linking is not available"})
+		return 0, 0, false
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return 0, 0, false
+	}
+	return uint(orgID), userID, true
+}
+
+// RunIdentityMigration merges the organization's duplicate accounts. It is
+// a dry run that only reports the plan unless dry_run is false.
+// POST /api/v1/enterprise/organizations/:id/identity-migration
+func (h *EnterpriseHandler) RunIdentityMigration(c *gin.Context) {
+	orgID, userID, ok := h.identityAdminRequest(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		DryRun *bool `json:"dry_run"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+	dryRun := req.DryRun == nil || *req.DryRun
+
+	report, err := h.identities.MigrateOrganization(orgID, &userID, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// MergeMemberAccounts merges one member's account into another with the
+// same email. Both must belong to the organization.
+// POST /api/v1/enterprise/organizations/:id/members/merge
+func (h *EnterpriseHandler) MergeMemberAccounts(c *gin.Context) {
+	orgID, userID, ok := h.identityAdminRequest(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		SourceUserID uint `json:"source_user_id" binding:"required"`
+		TargetUserID uint `json:"target_user_id" binding:"required"`
+		DryRun       bool `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	var members int64
+	h.db.Model(&enterprise.OrganizationMember{}).
+		Where("organization_id = ? AND user_id IN ?", orgID, []uint{req.SourceUserID, req.TargetUserID}).
+		Distinct("user_id").Count(&members)
+	if members != 2 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Both accounts must be members of this organization"})
+		return
+	}
+
+	var plan *enterprise.MergePlan
+	var err error
+	if req.DryRun {
+		plan, err = h.identities.PlanMerge(req.SourceUserID, req.TargetUserID)
+	} else {
+		plan, err = h.identities.Merge(req.SourceUserID, req.TargetUserID, &orgID, &userID)
+	}
+	switch {
+	case errors.Is(err, enterprise.ErrInvalidMerge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, enterprise.ErrMergeBlocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "plan": plan})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"dry_run": req.DryRun,
+		"plan":    plan,
+	})
+}
+
+// ListOrganizationDomains returns the email domains the organization has
+// claimed and whether each is verified
+// GET /api/v1/enterprise/organizations/:id/domains
+func (h *EnterpriseHandler) ListOrganizationDomains(c *gin.Context) {
+	orgID, _, ok := h.identityAdminRequest(c)
+	if !ok {
+		return
+	}
+	domains, err := h.identities.ListDomains(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "domains": domains})
+}
+
+// AddOrganizationDomain claims an email domain. SSO sign-ins from it link
+// to existing accounts only once the TXT record is verified.
+// POST /api/v1/enterprise/organizations/:id/domains
+func (h *EnterpriseHandler) AddOrganizationDomain(c *gin.Context) {
+	orgID, _, ok := h.identityAdminRequest(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Domain string `json:"domain" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	domain, err := h.identities.AddDomain(orgID, req.Domain)
+	switch {
+	case errors.Is(err, enterprise.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"domain":  domain,
+		"dns_record": gin.H{
+			"type":  "TXT",
+			"name":  domain.RecordName(),
+			"value": domain.VerificationToken,
+		},
+	})
+}
+
+// VerifyOrganizationDomain checks the domain's TXT record
+// POST /api/v1/enterprise/organizations/:id/domains/:domainId/verify
+func (h *EnterpriseHandler) VerifyOrganizationDomain(c *gin.Context) {
+	orgID, _, ok := h.identityAdminRequest(c)
+	if !ok {
+		return
+	}
+	domainID, err := strconv.ParseUint(c.Param("domainId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+	domain, err := h.identities.VerifyDomain(orgID, uint(domainID))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	case errors.Is(err, enterprise.ErrDomainNotVerified):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "domain": domain})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "domain": domain})
+}
+
+// DeleteOrganizationDomain releases a claimed domain
+// DELETE /api/v1/enterprise/organizations/:id/domains/:domainId
+func (h *EnterpriseHandler) DeleteOrganizationDomain(c *gin.Context) {
+	orgID, _, ok := h.identityAdminRequest(c)
+	if !ok {
+		return
+	}
+	domainID, err := strconv.ParseUint(c.Param("domainId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+	if err := h.identities.DeleteDomain(orgID, uint(domainID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,133 @@
+// APEX.BUILD Enterprise Network Policy Handlers
+// Organization IP allowlists and geo restrictions
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetNetworkPolicyService enables organization network policies and their
+// enforcement on SSO sign-in
+func (h *EnterpriseHandler) SetNetworkPolicyService(service *enterprise.NetworkPolicyService) {
+	h.networkPolicies = service
+}
+
+// NetworkPolicyMiddleware refuses API requests from networks the caller's
+// organizations do not allow
+func (h *EnterpriseHandler) NetworkPolicyMiddleware() gin.HandlerFunc {
+	if h.networkPolicies == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return h.networkPolicies.Middleware()
+}
+
+func (h *EnterpriseHandler) networkPolicyRequest(c *gin.Context, action string) (uint, uint, bool) {
+	if h.networkPolicies == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Network policies are not available"})
+		return 0, 0, false
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", action) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return 0, 0, false
+	}
+	return uint(orgID), userID, true
+}
+
+// GetNetworkPolicy returns an organization's network policy
+// GET /api/v1/enterprise/organizations/:id/network-policy
+func (h *EnterpriseHandler) GetNetworkPolicy(c *gin.Context) {
+	orgID, _, ok := h.networkPolicyRequest(c, "manage")
+	if !ok {
+		return
+	}
+
+	policy, err := h.networkPolicies.Get(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"policy":  policy,
+	})
+}
+
+// UpdateNetworkPolicy replaces an organization's network policy
+// PUT /api/v1/enterprise/organizations/:id/network-policy
+func (h *EnterpriseHandler) UpdateNetworkPolicy(c *gin.Context) {
+	orgID, userID, ok := h.networkPolicyRequest(c, "manage")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled           bool     `json:"enabled"`
+		EnforceAPI        *bool    `json:"enforce_api"`
+		EnforceSSO        *bool    `json:"enforce_sso"`
+		AllowedCIDRs      []string `json:"allowed_cidrs"`
+		AllowedCountries  []string `json:"allowed_countries"`
+		BlockedCountries  []string `json:"blocked_countries"`
+		BreakGlassUserIDs []uint   `json:"break_glass_user_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	policy := &enterprise.NetworkPolicy{
+		Enabled:           req.Enabled,
+		EnforceAPI:        req.EnforceAPI == nil || *req.EnforceAPI,
+		EnforceSSO:        req.EnforceSSO == nil || *req.EnforceSSO,
+		AllowedCIDRs:      req.AllowedCIDRs,
+		AllowedCountries:  req.AllowedCountries,
+		BlockedCountries:  req.BlockedCountries,
+		BreakGlassUserIDs: req.BreakGlassUserIDs,
+	}
+
+	saved, err := h.networkPolicies.Save(orgID, userID, policy, c.Request, c.ClientIP())
+	switch {
+	case errors.Is(err, enterprise.ErrInvalidNetworkPolicy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, enterprise.ErrNetworkPolicyLockout):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "error_code": "network_policy_lockout"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"policy":  saved,
+	})
+}
+
+// ssoNetworkAllowed refuses SSO sign-ins from networks the organization
+// does not allow
+func (h *EnterpriseHandler) ssoNetworkAllowed(c *gin.Context, org *enterprise.Organization) bool {
+	if h.networkPolicies == nil {
+		return true
+	}
+	if denial := h.networkPolicies.CheckSSO(org, c.Request, c.ClientIP()); denial != nil {
+		enterprise.RespondNetworkDenied(c, denial)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+// APEX.BUILD Enterprise Session Handlers
+// Forced logout of organization members
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/auth"
+	"apex-build/internal/enterprise"
+	"apex-build/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetAuthService enables forced logout of organization members
+func (h *EnterpriseHandler) SetAuthService(authService *auth.AuthService) {
+	h.sessions = authService
+}
+
+// ForceLogoutMembers signs organization members out of every device. With
+// :userId only that member is signed out; otherwise every member except
+// the caller.
+// POST /api/v1/enterprise/organizations/:id/logout
+// POST /api/v1/enterprise/organizations/:id/members/:userId/logout
+func (h *EnterpriseHandler) ForceLogoutMembers(c *gin.Context) {
+	if h.sessions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Session management is not available"})
+		return
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	if !h.rbacService.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin permission required"})
+		return
+	}
+
+	query := h.db.Model(&enterprise.OrganizationMember{}).Where("organization_id = ?", orgID)
+	if target := c.Param("userId"); target != "" {
+		targetID, err := strconv.ParseUint(target, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		query = query.Where("user_id = ?", targetID)
+	} else {
+		query = query.Where("user_id <> ?", userID)
+	}
+	var memberIDs []uint
+	if err := query.Pluck("user_id", &memberIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(memberIDs) == 0 && c.Param("userId") != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	revoked := 0
+	for _, memberID := range memberIDs {
+		count, err := h.sessions.RevokeAllSessions(memberID, "")
+		revoked += count
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "revoked_sessions": revoked})
+			return
+		}
+	}
+
+	org := uint(orgID)
+	h.auditService.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org,
+		UserID:         &userID,
+		Action:         "members_logged_out",
+		ResourceType:   "organization",
+		ResourceID:     strconv.FormatUint(orgID, 10),
+		Category:       "security",
+		Description:    "Organization admin signed members out of all sessions",
+		NewValue: map[string]interface{}{
+			"user_ids":         memberIDs,
+			"revoked_sessions": revoked,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"members":          len(memberIDs),
+		"revoked_sessions": revoked,
+	})
+}
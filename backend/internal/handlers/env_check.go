@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/envcheck"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// EnvCheckHandler reports environment variables a project's code reads but
+// its environments lack, and creates placeholder secrets for them
+type EnvCheckHandler struct {
+	db      *gorm.DB
+	checker *envcheck.Checker
+	access  collaboration.AccessResolver
+}
+
+// NewEnvCheckHandler creates a new EnvCheckHandler
+func NewEnvCheckHandler(db *gorm.DB, checker *envcheck.Checker) *EnvCheckHandler {
+	return &EnvCheckHandler{db: db, checker: checker}
+}
+
+// SetAccessResolver lets collaborators and organization members in,
+// according to their permission on the project
+func (h *EnvCheckHandler) SetAccessResolver(access collaboration.AccessResolver) {
+	h.access = access
+}
+
+// RegisterRoutes registers the environment variable check routes on the
+// protected group
+func (h *EnvCheckHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/env-check", h.GetEnvCheck)
+	rg.POST("/projects/:id/env-check/placeholders", h.CreatePlaceholders)
+}
+
+// GetEnvCheck compares the project's code with its configured variables
+func (h *EnvCheckHandler) GetEnvCheck(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, nil)
+	if !ok {
+		return
+	}
+	report, err := h.checker.CheckProject(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check environment variables"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "env_var_check": report})
+}
+
+// CreatePlaceholdersRequest picks the missing variables to create
+// placeholders for; an empty list takes every one the check suggests
+type CreatePlaceholdersRequest struct {
+	Names []string `json:"names"`
+}
+
+// CreatePlaceholders creates empty project secrets for variables the code
+// reads but the workspace lacks. Only names the check reports as missing
+// are accepted, then the check runs again for the response.
+func (h *EnvCheckHandler) CreatePlaceholders(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	if rejectArchivedProject(c, project) {
+		return
+	}
+	var req CreatePlaceholdersRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+			return
+		}
+	}
+
+	report, err := h.checker.CheckProject(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check environment variables"})
+		return
+	}
+	creatable := map[string]bool{}
+	var names []string
+	for _, missing := range report.Missing {
+		if missing.Action == envcheck.ActionCreatePlaceholder {
+			creatable[missing.Name] = true
+			names = append(names, missing.Name)
+		}
+	}
+	if len(req.Names) > 0 {
+		for _, name := range req.Names {
+			if !creatable[name] {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": name + " is not a missing variable of this project"})
+				return
+			}
+		}
+		names = req.Names
+	}
+
+	created, err := h.checker.CreatePlaceholders(project.ID, project.OwnerID, names)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create placeholder secrets"})
+		return
+	}
+	if report, err = h.checker.CheckProject(project.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check environment variables"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "created": created, "env_var_check": report})
+}
@@ -7,6 +7,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 
@@ -75,6 +76,13 @@ type EnvironmentConfig struct {
 	// Environment variables (non-secret)
 	EnvVars map[string]string `json:"env_vars"`
 
+	// Variable names (glob patterns) kept out of preview processes, on top
+	// of the built-in prod-only exclusions
+	PreviewExcludeEnv []string `json:"preview_exclude_env,omitempty"`
+
+	// How the preview proxy presents requests to the app
+	PreviewProxy PreviewProxyConfig `json:"preview_proxy"`
+
 	// Build configuration
 	BuildCommand   string `json:"build_command,omitempty"`   // Custom build command
 	StartCommand   string `json:"start_command,omitempty"`   // Custom start command
@@ -84,6 +92,17 @@ type EnvironmentConfig struct {
 	Options map[string]interface{} `json:"options,omitempty"` // Runtime-specific options
 }
 
+// PreviewProxyConfig tunes the preview proxy for apps that care about the
+// public URL they are served under
+type PreviewProxyConfig struct {
+	// PreservePrefix forwards the full /api/v1/preview/... path instead of
+	// stripping it, for apps built with a matching base path
+	PreservePrefix bool `json:"preserve_prefix"`
+	// KeepCookies passes Set-Cookie headers through unchanged instead of
+	// scoping them to the preview's host and path
+	KeepCookies bool `json:"keep_cookies"`
+}
+
 // PackageDependency represents a package dependency
 type PackageDependency struct {
 	Name    string `json:"name"`              // Package name
@@ -775,6 +794,12 @@ func validateEnvironmentConfig(config *EnvironmentConfig) string {
 		}
 	}
 
+	for _, pattern := range config.PreviewExcludeEnv {
+		if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return "Invalid preview exclusion pattern: " + pattern
+		}
+	}
+
 	return ""
 }
 
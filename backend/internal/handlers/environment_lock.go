@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/envlock"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SetEnvironmentLocks verifies projects against their apex.lock when a
+// preview starts and reports drift in the response
+func (h *PreviewHandler) SetEnvironmentLocks(service *envlock.Service) {
+	h.environmentLocks = service
+}
+
+// verifyEnvironmentLock checks a project against its apex.lock before a
+// preview or execution, writing the lock on first use. The check only
+// warns, so a failure is logged and yields nil rather than blocking the run.
+func verifyEnvironmentLock(service *envlock.Service, projectID, userID uint, source string) *envlock.Check {
+	if service == nil {
+		return nil
+	}
+	check, err := service.Verify(projectID, userID, source)
+	if err != nil {
+		log.Printf("Project %d: failed to verify %s: %v", projectID, envlock.FileName, err)
+		return nil
+	}
+	if check.Warning != "" {
+		log.Printf("Project %d: %s", projectID, check.Warning)
+	}
+	return check
+}
+
+// EnvironmentLockHandler verifies projects against their apex.lock and
+// accepts drift by regenerating the lock
+type EnvironmentLockHandler struct {
+	db      *gorm.DB
+	service *envlock.Service
+	access  collaboration.AccessResolver
+}
+
+// NewEnvironmentLockHandler creates a new EnvironmentLockHandler
+func NewEnvironmentLockHandler(db *gorm.DB, service *envlock.Service) *EnvironmentLockHandler {
+	return &EnvironmentLockHandler{db: db, service: service}
+}
+
+// SetAccessResolver lets collaborators and organization members in,
+// according to their permission on the project
+func (h *EnvironmentLockHandler) SetAccessResolver(access collaboration.AccessResolver) {
+	h.access = access
+}
+
+// RegisterRoutes registers the environment lock routes on the protected group
+func (h *EnvironmentLockHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/environment-lock", h.GetEnvironmentLock)
+	rg.POST("/projects/:id/environment-lock", h.RegenerateEnvironmentLock)
+}
+
+// GetEnvironmentLock compares the project against its apex.lock, writing one
+// if the project has none yet
+func (h *EnvironmentLockHandler) GetEnvironmentLock(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, nil)
+	if !ok {
+		return
+	}
+	check, err := h.service.Verify(project.ID, c.GetUint("user_id"), envlock.SourceExecution)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to verify environment lock"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "environment_lock": check})
+}
+
+// RegenerateEnvironmentLock replaces apex.lock with the project's current
+// environment, accepting any drift
+func (h *EnvironmentLockHandler) RegenerateEnvironmentLock(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	if rejectArchivedProject(c, project) {
+		return
+	}
+	lock, err := h.service.Regenerate(project.ID, c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to regenerate environment lock"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "environment_lock": &envlock.Check{Status: envlock.StatusOK, Lock: lock}})
+}
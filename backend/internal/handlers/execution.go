@@ -10,6 +10,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -20,8 +21,13 @@ import (
 	"strings"
 	"time"
 
+	"apex-build/internal/abuse"
+	"apex-build/internal/envlock"
 	"apex-build/internal/execution"
 	"apex-build/internal/middleware"
+	"apex-build/internal/pagination"
+	"apex-build/internal/runartifacts"
+	"apex-build/internal/secrets"
 	"apex-build/internal/usage"
 	"apex-build/pkg/models"
 
@@ -87,6 +93,37 @@ type ExecutionHandler struct {
 	// When true, execution will fail if Docker is unavailable
 	ContainerRequired bool
 	UsageTracker      *usage.Tracker
+	// ExecutionQuota reserves minutes before a run and settles them with the
+	// measured time afterwards. When nil, usage is recorded after the fact.
+	ExecutionQuota ExecutionQuota
+	// AbuseService screens code before it runs and reviews finished runs for
+	// mining and spam. When nil, no abuse checks are made.
+	AbuseService *abuse.Service
+	// EnvironmentLocks verifies projects against their apex.lock before they
+	// run and reports drift. When nil, no lock is checked.
+	EnvironmentLocks *envlock.Service
+	// Artifacts keeps the files project runs write when the request opts in
+	// to capture. When nil, capture is unavailable.
+	Artifacts *runartifacts.Service
+	// StorageQuota checks artifacts saved into a project against the storage
+	// limit of whoever pays for it. When nil, no limit is applied.
+	StorageQuota StorageQuota
+	// ProjectTokens gives scheduled runs their project's service token.
+	// When nil, scheduled runs get no token.
+	ProjectTokens *secrets.ProjectTokens
+}
+
+// ExecutionQuota reserves and settles execution minutes around a sandbox run.
+type ExecutionQuota interface {
+	ReserveExecution(c *gin.Context, timeout time.Duration) (string, bool)
+	SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) error
+	ReleaseExecution(ctx context.Context, reservationID string) error
+}
+
+// StorageQuota checks a write growing a project by bytes; when it returns
+// false the quota response has already been written.
+type StorageQuota interface {
+	AllowProjectStorageDelta(c *gin.Context, projectID uint, bytes int64) bool
 }
 
 // ExecutionHandlerConfig configures the execution handler
@@ -237,6 +274,90 @@ func (h *ExecutionHandler) SetUsageTracker(tracker *usage.Tracker) {
 	h.UsageTracker = tracker
 }
 
+func (h *ExecutionHandler) SetExecutionQuota(quota ExecutionQuota) {
+	h.ExecutionQuota = quota
+}
+
+func (h *ExecutionHandler) SetAbuseService(service *abuse.Service) {
+	h.AbuseService = service
+}
+
+func (h *ExecutionHandler) SetEnvironmentLocks(service *envlock.Service) {
+	h.EnvironmentLocks = service
+}
+
+// screenExecution caps the timeout of throttled accounts and refuses code
+// that matches a blocking abuse pattern. It returns false after writing the
+// response when the run must not start.
+func (h *ExecutionHandler) screenExecution(c *gin.Context, execRecord *models.Execution, timeout *time.Duration, sources ...string) bool {
+	if limit, ok := c.Get("abuse_max_timeout"); ok {
+		if d, ok := limit.(time.Duration); ok && d > 0 && *timeout > d {
+			*timeout = d
+		}
+	}
+	if h.AbuseService == nil {
+		return true
+	}
+	decision := h.AbuseService.Screen(c.Request.Context(), execRecord.UserID, execRecord.ExecutionID, sources...)
+	if decision.Allowed {
+		return true
+	}
+	if execRecord.ID > 0 {
+		markExecutionFailed(h.DB, execRecord, decision.Reason)
+	}
+	c.JSON(http.StatusForbidden, StandardResponse{
+		Success: false,
+		Error:   decision.Reason,
+		Code:    decision.Code,
+	})
+	return false
+}
+
+// observeExecution hands a finished run to abuse detection in the background.
+func (h *ExecutionHandler) observeExecution(userID uint, executionID string, result *execution.ExecutionResult) {
+	if h.AbuseService == nil || result == nil {
+		return
+	}
+	run := abuse.Run{
+		ExecutionID: executionID,
+		Output:      result.Output + "\n" + result.ErrorOutput,
+		DurationMs:  result.DurationMs,
+		CPUTimeMs:   result.CPUTime,
+	}
+	go h.AbuseService.Observe(context.Background(), userID, run)
+}
+
+// reserveExecution holds minutes for a run of up to timeout. It returns false
+// after writing the quota response when the budget can't cover the run.
+func (h *ExecutionHandler) reserveExecution(c *gin.Context, timeout time.Duration) (string, bool) {
+	if h.ExecutionQuota == nil {
+		return "", true
+	}
+	return h.ExecutionQuota.ReserveExecution(c, timeout)
+}
+
+// releaseExecution returns the reserved minutes of a run that failed to start.
+func (h *ExecutionHandler) releaseExecution(ctx context.Context, reservationID string) {
+	if h.ExecutionQuota == nil || reservationID == "" {
+		return
+	}
+	if err := h.ExecutionQuota.ReleaseExecution(ctx, reservationID); err != nil {
+		log.Printf("usage tracker: failed to release execution reservation %s: %v", reservationID, err)
+	}
+}
+
+// settleExecutionUsage charges the measured wall/CPU time of a finished run,
+// reconciling it against the reservation made before it started.
+func (h *ExecutionHandler) settleExecutionUsage(ctx context.Context, reservationID string, userID uint, projectID *uint, result *execution.ExecutionResult) {
+	if h.ExecutionQuota == nil || reservationID == "" {
+		h.recordExecutionUsage(ctx, userID, projectID, result.DurationMs)
+		return
+	}
+	if err := h.ExecutionQuota.SettleExecution(ctx, reservationID, projectID, result.DurationMs, result.CPUTime); err != nil {
+		log.Printf("usage tracker: failed to settle execution usage for user %d: %v", userID, err)
+	}
+}
+
 func (h *ExecutionHandler) recordExecutionUsage(ctx context.Context, userID uint, projectID *uint, durationMs int64) {
 	if h.UsageTracker == nil {
 		return
@@ -369,12 +490,21 @@ func (h *ExecutionHandler) ExecuteCode(c *gin.Context) {
 	if req.Timeout > 0 && req.Timeout <= 120 {
 		timeout = time.Duration(req.Timeout) * time.Second
 	}
+	if !h.screenExecution(c, execRecord, &timeout, req.Code) {
+		return
+	}
+	reservationID, ok := h.reserveExecution(c, timeout)
+	if !ok {
+		markExecutionFailed(h.DB, execRecord, "Execution quota exceeded")
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	// Execute code using the secure sandbox
 	result, err := h.SandboxFactory.ExecuteWithID(ctx, execRecord.ExecutionID, req.Language, req.Code, req.Stdin)
 	if err != nil {
+		h.releaseExecution(c.Request.Context(), reservationID)
 		markExecutionFailed(h.DB, execRecord, "Execution failed: "+err.Error())
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
@@ -401,7 +531,8 @@ func (h *ExecutionHandler) ExecuteCode(c *gin.Context) {
 		log.Printf("Failed to update execution record: %v", err)
 	}
 
-	h.recordExecutionUsage(c.Request.Context(), userID, execRecord.ProjectID, result.DurationMs)
+	h.settleExecutionUsage(c.Request.Context(), reservationID, userID, execRecord.ProjectID, result)
+	h.observeExecution(userID, execRecord.ExecutionID, result)
 
 	// Include sandbox info in response for transparency
 	sandboxInfo := "container"
@@ -559,6 +690,16 @@ func (h *ExecutionHandler) ExecuteFile(c *gin.Context) {
 	if req.Timeout > 0 && req.Timeout <= 120 {
 		timeout = time.Duration(req.Timeout) * time.Second
 	}
+	if !h.screenExecution(c, execRecord, &timeout, file.Content, strings.Join(req.Args, " ")) {
+		return
+	}
+	reservationID, ok := h.reserveExecution(c, timeout)
+	if !ok {
+		if execRecord.ID > 0 {
+			markExecutionFailed(h.DB, execRecord, "Execution quota exceeded")
+		}
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -571,6 +712,7 @@ func (h *ExecutionHandler) ExecuteFile(c *gin.Context) {
 			// Read file content and execute as code
 			result, err = h.SandboxFactory.ExecuteWithID(ctx, execRecord.ExecutionID, file.Project.Language, file.Content, req.Stdin)
 			if err != nil {
+				h.releaseExecution(c.Request.Context(), reservationID)
 				if execRecord.ID > 0 {
 					markExecutionFailed(h.DB, execRecord, "Execution failed: "+err.Error())
 				}
@@ -582,6 +724,7 @@ func (h *ExecutionHandler) ExecuteFile(c *gin.Context) {
 				return
 			}
 		} else {
+			h.releaseExecution(c.Request.Context(), reservationID)
 			if execRecord.ID > 0 {
 				markExecutionFailed(h.DB, execRecord, "Execution failed: "+err.Error())
 			}
@@ -611,7 +754,8 @@ func (h *ExecutionHandler) ExecuteFile(c *gin.Context) {
 		}
 	}
 
-	h.recordExecutionUsage(c.Request.Context(), userID, execRecord.ProjectID, result.DurationMs)
+	h.settleExecutionUsage(c.Request.Context(), reservationID, userID, execRecord.ProjectID, result)
+	h.observeExecution(userID, execRecord.ExecutionID, result)
 
 	// Include sandbox info
 	sandboxInfo := "container"
@@ -640,8 +784,12 @@ func (h *ExecutionHandler) ExecuteFile(c *gin.Context) {
 type ExecuteProjectRequest struct {
 	ProjectID uint              `json:"project_id" binding:"required"`
 	Command   string            `json:"command"` // Optional: custom run command
+	Mode      string            `json:"mode"`    // Optional: "run" (default) or "test"
 	Env       map[string]string `json:"env"`
 	Timeout   int               `json:"timeout"`
+	// CaptureArtifacts returns the files the run creates or modifies and keeps
+	// them so they can be saved into the project.
+	CaptureArtifacts bool `json:"capture_artifacts"`
 }
 
 // ExecuteProject handles POST /api/v1/execute/project
@@ -708,6 +856,10 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 		})
 		return
 	}
+	if rejectArchivedProject(c, &project) {
+		return
+	}
+	environmentLock := verifyEnvironmentLock(h.EnvironmentLocks, project.ID, userID, envlock.SourceExecution)
 
 	// Create project directory
 	projectDir := filepath.Join(h.ProjectsDir, fmt.Sprintf("project-%d-%s", project.ID, uuid.New().String()[:8]))
@@ -722,13 +874,8 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 	defer os.RemoveAll(projectDir)
 
 	// Write all project files
-	for _, file := range project.Files {
-		projectPath := file.Path
-		if strings.TrimSpace(projectPath) == "" {
-			projectPath = file.Name
-		}
-		normalizedPath, err := normalizeProjectFilePath(projectPath)
-		if err != nil {
+	if err := writeProjectWorkspace(projectDir, project.Files); err != nil {
+		if errors.Is(err, errInvalidProjectPath) {
 			c.JSON(http.StatusInternalServerError, StandardResponse{
 				Success: false,
 				Error:   "Project contains an invalid file path",
@@ -736,40 +883,12 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 			})
 			return
 		}
-		if normalizedPath == "" {
-			continue
-		}
-
-		if file.Type == "directory" {
-			if err := os.MkdirAll(filepath.Join(projectDir, normalizedPath), 0755); err != nil {
-				c.JSON(http.StatusInternalServerError, StandardResponse{
-					Success: false,
-					Error:   "Failed to prepare project directory",
-					Code:    "SYSTEM_ERROR",
-				})
-				return
-			}
-			continue
-		}
-
-		filePath := filepath.Join(projectDir, normalizedPath)
-		fileDir := filepath.Dir(filePath)
-		if err := os.MkdirAll(fileDir, 0755); err != nil {
-			c.JSON(http.StatusInternalServerError, StandardResponse{
-				Success: false,
-				Error:   "Failed to prepare project files",
-				Code:    "SYSTEM_ERROR",
-			})
-			return
-		}
-		if err := os.WriteFile(filePath, []byte(file.Content), 0644); err != nil {
-			c.JSON(http.StatusInternalServerError, StandardResponse{
-				Success: false,
-				Error:   "Failed to write project files",
-				Code:    "SYSTEM_ERROR",
-			})
-			return
-		}
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to prepare project files",
+			Code:    "SYSTEM_ERROR",
+		})
+		return
 	}
 
 	// Determine run command
@@ -779,7 +898,11 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 	}
 	runCmd := strings.TrimSpace(req.Command)
 	if runCmd == "" {
-		runCmd = getDefaultRunCommand(project.Language, project.Framework, entryPoint)
+		if strings.EqualFold(strings.TrimSpace(req.Mode), "test") {
+			runCmd = getDefaultTestCommand(project.Language, project.Framework)
+		} else {
+			runCmd = getDefaultRunCommand(project.Language, project.Framework, entryPoint)
+		}
 	}
 	if strings.TrimSpace(runCmd) == "" || strings.Contains(runCmd, "No run command configured") {
 		c.JSON(http.StatusBadRequest, StandardResponse{
@@ -829,11 +952,24 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 	if req.Timeout > 0 && req.Timeout <= 300 {
 		timeout = time.Duration(req.Timeout) * time.Second
 	}
+	sources := []string{runCmd}
+	for _, f := range project.Files {
+		sources = append(sources, f.Content)
+	}
+	if !h.screenExecution(c, execRecord, &timeout, sources...) {
+		return
+	}
+	reservationID, ok := h.reserveExecution(c, timeout)
+	if !ok {
+		markExecutionFailed(h.DB, execRecord, "Execution quota exceeded")
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	result, err := h.SandboxFactory.ExecuteWorkspaceCommandWithID(ctx, execRecord.ExecutionID, project.Language, projectDir, runCmd, "", req.Env)
 	if err != nil {
+		h.releaseExecution(c.Request.Context(), reservationID)
 		markExecutionFailed(h.DB, execRecord, "Project execution failed: "+err.Error())
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
@@ -857,7 +993,8 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 		log.Printf("Failed to update project execution record: %v", err)
 	}
 
-	h.recordExecutionUsage(c.Request.Context(), userID, execRecord.ProjectID, result.DurationMs)
+	h.settleExecutionUsage(c.Request.Context(), reservationID, userID, execRecord.ProjectID, result)
+	h.observeExecution(userID, execRecord.ExecutionID, result)
 
 	// Include sandbox info
 	sandboxInfo := "container"
@@ -865,20 +1002,27 @@ func (h *ExecutionHandler) ExecuteProject(c *gin.Context) {
 		sandboxInfo = "process"
 	}
 
+	data := map[string]interface{}{
+		"id":               execRecord.ExecutionID,
+		"status":           result.Status,
+		"output":           result.Output,
+		"error_output":     result.ErrorOutput,
+		"exit_code":        result.ExitCode,
+		"duration_ms":      result.DurationMs,
+		"memory_used":      result.MemoryUsed,
+		"timed_out":        result.TimedOut,
+		"command":          runCmd,
+		"sandbox_type":     sandboxInfo,
+		"environment_lock": environmentLock,
+	}
+	// Collect the run's output files before the workspace is removed
+	if req.CaptureArtifacts && h.Artifacts != nil {
+		data["artifacts"] = h.captureExecutionArtifacts(execRecord.ExecutionID, &project, userID, projectDir)
+	}
+
 	c.JSON(http.StatusOK, StandardResponse{
 		Success: true,
-		Data: map[string]interface{}{
-			"id":           execRecord.ExecutionID,
-			"status":       result.Status,
-			"output":       result.Output,
-			"error_output": result.ErrorOutput,
-			"exit_code":    result.ExitCode,
-			"duration_ms":  result.DurationMs,
-			"memory_used":  result.MemoryUsed,
-			"timed_out":    result.TimedOut,
-			"command":      runCmd,
-			"sandbox_type": sandboxInfo,
-		},
+		Data:    data,
 	})
 }
 
@@ -933,6 +1077,16 @@ func (h *ExecutionHandler) GetExecution(c *gin.Context) {
 	})
 }
 
+// executionListSpec is the pagination spec for execution history.
+var executionListSpec = pagination.Spec{
+	DefaultLimit: 10,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "duration", Column: "duration", Desc: true},
+	},
+}
+
 // GetExecutionHistory handles GET /api/v1/execute/history
 func (h *ExecutionHandler) GetExecutionHistory(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -945,7 +1099,16 @@ func (h *ExecutionHandler) GetExecutionHistory(c *gin.Context) {
 		return
 	}
 
-	page, limit := parsePaginationParams(c)
+	page, _ := parsePaginationParams(c)
+	list, err := pagination.Parse(c, executionListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_PAGINATION",
+		})
+		return
+	}
 
 	// Optional project filter
 	projectIDStr := c.Query("project_id")
@@ -968,7 +1131,7 @@ func (h *ExecutionHandler) GetExecutionHistory(c *gin.Context) {
 
 	// Get executions
 	var executions []models.Execution
-	if err := query.Order("created_at DESC").Scopes(paginate(page, limit)).Find(&executions).Error; err != nil {
+	if err := list.Apply(query).Find(&executions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
 			Error:   "Database error",
@@ -976,13 +1139,29 @@ func (h *ExecutionHandler) GetExecutionHistory(c *gin.Context) {
 		})
 		return
 	}
+	executions, pageInfo := pagination.Page(list, executions, func(e models.Execution) (any, any) {
+		if list.Sort.Key == "duration" {
+			return e.Duration, e.ID
+		}
+		return e.CreatedAt, e.ID
+	})
+	data, err := pagination.SelectFields(executions, list.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to select fields",
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, PaginatedResponse{
 		StandardResponse: StandardResponse{
 			Success: true,
-			Data:    executions,
+			Data:    data,
 		},
-		Pagination: getPaginationInfo(page, limit, total),
+		Pagination: getPaginationInfo(page, list.Limit, total),
+		PageInfo:   &pageInfo,
 	})
 }
 
@@ -1262,6 +1441,68 @@ func getDefaultRunCommand(language, framework, entryPoint string) string {
 		}
 		return "php -S localhost:8000"
 
+	case "kotlin":
+		if safeEntryPoint != "" && !strings.HasPrefix(safeEntryPoint, "src/") {
+			return "kotlinc " + safeEntryPoint + " -nowarn -include-runtime -d /tmp/main.jar && java -jar /tmp/main.jar"
+		}
+		return "gradle run"
+
+	case "swift":
+		if safeEntryPoint != "" && !strings.HasPrefix(safeEntryPoint, "Sources/") {
+			return "swiftc -o /tmp/main " + safeEntryPoint + " && /tmp/main"
+		}
+		return "swift run"
+
+	case "csharp":
+		return "dotnet run"
+
+	case "elixir":
+		if framework == "phoenix" {
+			return "mix phx.server"
+		}
+		if safeEntryPoint != "" && strings.HasSuffix(safeEntryPoint, ".exs") {
+			return "elixir " + safeEntryPoint
+		}
+		return "mix run --no-halt"
+
+	default:
+		return "echo 'No run command configured'"
+	}
+}
+
+// getDefaultTestCommand returns the project's test runner command.
+func getDefaultTestCommand(language, framework string) string {
+	switch language {
+	case "javascript", "typescript":
+		return "npm test"
+	case "python":
+		if framework == "django" {
+			return "python manage.py test"
+		}
+		return "python3 -m pytest"
+	case "go":
+		return "go test ./..."
+	case "rust":
+		return "cargo test"
+	case "java":
+		return "mvn test"
+	case "kotlin":
+		return "gradle test"
+	case "swift":
+		return "swift test"
+	case "csharp":
+		return "dotnet test"
+	case "elixir":
+		return "mix test"
+	case "ruby":
+		if framework == "rails" {
+			return "bin/rails test"
+		}
+		return "bundle exec rspec"
+	case "php":
+		return "vendor/bin/phpunit"
+	case "c", "cpp":
+		return "make test"
 	default:
 		return "echo 'No run command configured'"
 	}
@@ -1280,6 +1521,10 @@ func findEntryPoint(language string, files []models.File) string {
 		"php":        {"index.php", "main.php", "app.php"},
 		"c":          {"main.c"},
 		"cpp":        {"main.cpp", "main.cc"},
+		"kotlin":     {"main.kt", "Main.kt", "src/main/kotlin/Main.kt", "src/main/kotlin/Application.kt"},
+		"swift":      {"main.swift", "Sources/main.swift"},
+		"csharp":     {"Program.cs"},
+		"elixir":     {"main.exs"},
 	}
 
 	patterns, ok := entryPoints[language]
@@ -1355,3 +1600,154 @@ func (h *ExecutionHandler) GetSandboxStatus() map[string]interface{} {
 
 	return status
 }
+
+// RunProjectCommand runs command in a fresh copy of the project's workspace
+// in the container sandbox and records it in execution history. Scheduled
+// tasks use it outside a request, so callers handle quota themselves.
+// The run receives the project's service token, if it has one.
+func (h *ExecutionHandler) RunProjectCommand(ctx context.Context, projectID uint, command string, timeout time.Duration) (*execution.ExecutionResult, error) {
+	return h.runProjectCommand(ctx, projectID, command, timeout, nil, h.projectTokenEnv(projectID))
+}
+
+// RunProjectCommandWithFiles runs command like RunProjectCommand, in a
+// workspace where overrides replace (or add) files by path. The stored
+// project files are not modified.
+func (h *ExecutionHandler) RunProjectCommandWithFiles(ctx context.Context, projectID uint, command string, timeout time.Duration, overrides map[string]string) (*execution.ExecutionResult, error) {
+	return h.runProjectCommand(ctx, projectID, command, timeout, overrides, nil)
+}
+
+func (h *ExecutionHandler) runProjectCommand(ctx context.Context, projectID uint, command string, timeout time.Duration, overrides, env map[string]string) (*execution.ExecutionResult, error) {
+	if h.SandboxFactory == nil || !h.SandboxFactory.IsContainerAvailable() {
+		return nil, fmt.Errorf("project execution requires the container sandbox")
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(ctx).Preload("Files").First(&project, projectID).Error; err != nil {
+		return nil, fmt.Errorf("project %d not found", projectID)
+	}
+	if project.IsArchived {
+		return nil, fmt.Errorf("project is archived")
+	}
+
+	return h.runWorkspaceCommand(ctx, &project.ID, project.OwnerID, project.Language,
+		fmt.Sprintf("project-%d", project.ID), overlayProjectFiles(project.Files, overrides), command, timeout, env)
+}
+
+// RunFilesCommand runs command in the container sandbox in a workspace made
+// only of files, keyed by path, and records it in execution history for
+// userID. Build hooks use it for generated output that has no project yet
+// and reserve execution minutes themselves.
+func (h *ExecutionHandler) RunFilesCommand(ctx context.Context, userID uint, language string, files map[string]string, command string, timeout time.Duration) (*execution.ExecutionResult, error) {
+	if h.SandboxFactory == nil || !h.SandboxFactory.IsContainerAvailable() {
+		return nil, fmt.Errorf("workspace execution requires the container sandbox")
+	}
+	return h.runWorkspaceCommand(ctx, nil, userID, language, fmt.Sprintf("files-%d", userID), overlayProjectFiles(nil, files), command, timeout, nil)
+}
+
+// runWorkspaceCommand writes files to a fresh directory, runs command there
+// with timeout and env, and records the execution.
+func (h *ExecutionHandler) runWorkspaceCommand(ctx context.Context, projectID *uint, userID uint, language, dirPrefix string, files []models.File, command string, timeout time.Duration, env map[string]string) (*execution.ExecutionResult, error) {
+	projectDir := filepath.Join(h.ProjectsDir, fmt.Sprintf("%s-%s", dirPrefix, uuid.New().String()[:8]))
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create project directory: %w", err)
+	}
+	defer os.RemoveAll(projectDir)
+	if err := writeProjectWorkspace(projectDir, files); err != nil {
+		return nil, err
+	}
+
+	execRecord := &models.Execution{
+		ExecutionID: uuid.New().String(),
+		ProjectID:   projectID,
+		UserID:      userID,
+		Language:    language,
+		Command:     command,
+		Status:      "running",
+		StartedAt:   time.Now(),
+	}
+	if err := h.DB.WithContext(ctx).Create(execRecord).Error; err != nil {
+		return nil, fmt.Errorf("failed to create execution record: %w", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := h.SandboxFactory.ExecuteWorkspaceCommandWithID(runCtx, execRecord.ExecutionID, language, projectDir, command, "", env)
+	if err != nil {
+		markExecutionFailed(h.DB, execRecord, "Project execution failed: "+err.Error())
+		return nil, err
+	}
+
+	execRecord.Output = result.Output
+	execRecord.ErrorOut = result.ErrorOutput
+	execRecord.ExitCode = result.ExitCode
+	execRecord.Status = result.Status
+	execRecord.Duration = result.DurationMs
+	execRecord.MemoryUsed = result.MemoryUsed
+	execRecord.CompletedAt = result.CompletedAt
+	if err := h.DB.Save(execRecord).Error; err != nil {
+		log.Printf("Failed to update project execution record: %v", err)
+	}
+	result.ID = execRecord.ExecutionID
+	return result, nil
+}
+
+// overlayProjectFiles returns files with overrides applied by path; paths
+// are compared without a leading slash.
+func overlayProjectFiles(files []models.File, overrides map[string]string) []models.File {
+	if len(overrides) == 0 {
+		return files
+	}
+	pending := make(map[string]string, len(overrides))
+	for path, content := range overrides {
+		pending[strings.TrimPrefix(path, "/")] = content
+	}
+	out := make([]models.File, 0, len(files)+len(pending))
+	for _, file := range files {
+		key := strings.TrimPrefix(file.Path, "/")
+		if content, ok := pending[key]; ok {
+			file.Content = content
+			file.Size = int64(len(content))
+			file.IsBinary = false
+			delete(pending, key)
+		}
+		out = append(out, file)
+	}
+	for path, content := range pending {
+		out = append(out, models.File{Path: path, Name: filepath.Base(path), Type: "file", Content: content, Size: int64(len(content))})
+	}
+	return out
+}
+
+// errInvalidProjectPath marks a project file whose path escapes the workspace.
+var errInvalidProjectPath = errors.New("project contains an invalid file path")
+
+// writeProjectWorkspace materializes project files under projectDir.
+func writeProjectWorkspace(projectDir string, files []models.File) error {
+	for _, file := range files {
+		projectPath := file.Path
+		if strings.TrimSpace(projectPath) == "" {
+			projectPath = file.Name
+		}
+		normalizedPath, err := normalizeProjectFilePath(projectPath)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidProjectPath, err)
+		}
+		if normalizedPath == "" {
+			continue
+		}
+		target := filepath.Join(projectDir, normalizedPath)
+		if file.Type == "directory" {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to prepare project directory: %w", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to prepare project files: %w", err)
+		}
+		if err := os.WriteFile(target, []byte(file.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write project files: %w", err)
+		}
+	}
+	return nil
+}
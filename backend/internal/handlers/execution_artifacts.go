@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"apex-build/internal/middleware"
+	"apex-build/internal/runartifacts"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *ExecutionHandler) SetArtifactStore(store *runartifacts.Service) {
+	h.Artifacts = store
+}
+
+func (h *ExecutionHandler) SetStorageQuota(quota StorageQuota) {
+	h.StorageQuota = quota
+}
+
+// captureExecutionArtifacts collects the files a project run created or
+// modified in its workspace and stores them for confirmation. Capture is best
+// effort: a failure is logged and the run's result is returned without them.
+func (h *ExecutionHandler) captureExecutionArtifacts(executionID string, project *models.Project, userID uint, workspaceDir string) []runartifacts.Artifact {
+	before := make(map[string]string, len(project.Files))
+	for _, f := range project.Files {
+		if f.Type == "directory" {
+			continue
+		}
+		projectPath := f.Path
+		if projectPath == "" {
+			projectPath = f.Name
+		}
+		if normalized, err := normalizeProjectFilePath(projectPath); err == nil && normalized != "" {
+			before[normalized] = f.Content
+		}
+	}
+	artifacts, err := runartifacts.Capture(workspaceDir, before)
+	if err != nil {
+		log.Printf("Execution %s: failed to capture artifacts: %v", executionID, err)
+		return nil
+	}
+	if err := h.Artifacts.Save(executionID, project.ID, userID, artifacts); err != nil {
+		log.Printf("Execution %s: failed to store artifacts: %v", executionID, err)
+		return nil
+	}
+	return artifacts
+}
+
+// GetExecutionArtifacts handles GET /api/v1/execute/:id/artifacts
+func (h *ExecutionHandler) GetExecutionArtifacts(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+	if h.Artifacts == nil {
+		c.JSON(http.StatusServiceUnavailable, StandardResponse{
+			Success: false,
+			Error:   "Artifact capture is not available",
+			Code:    "ARTIFACTS_UNAVAILABLE",
+		})
+		return
+	}
+
+	artifacts, err := h.Artifacts.List(c.Param("id"), userID)
+	if err != nil {
+		h.writeArtifactError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"execution_id": c.Param("id"),
+			"artifacts":    artifacts,
+		},
+	})
+}
+
+// ApplyExecutionArtifacts handles POST /api/v1/execute/:id/artifacts/apply.
+// It saves the confirmed artifacts, or all of them when no paths are given,
+// into the execution's project.
+func (h *ExecutionHandler) ApplyExecutionArtifacts(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+	if h.Artifacts == nil {
+		c.JSON(http.StatusServiceUnavailable, StandardResponse{
+			Success: false,
+			Error:   "Artifact capture is not available",
+			Code:    "ARTIFACTS_UNAVAILABLE",
+		})
+		return
+	}
+
+	var req struct {
+		Paths []string `json:"paths"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+		})
+		return
+	}
+
+	artifacts, err := h.Artifacts.List(c.Param("id"), userID)
+	if err != nil {
+		h.writeArtifactError(c, err)
+		return
+	}
+	// The project may have been transferred or archived since the run
+	var project models.Project
+	if err := h.DB.First(&project, artifacts[0].ProjectID).Error; err != nil || project.OwnerID != userID {
+		c.JSON(http.StatusForbidden, StandardResponse{
+			Success: false,
+			Error:   "Access denied",
+			Code:    "ACCESS_DENIED",
+		})
+		return
+	}
+	if rejectArchivedProject(c, &project) {
+		return
+	}
+
+	allow := func(delta int64) bool {
+		return h.StorageQuota == nil || h.StorageQuota.AllowProjectStorageDelta(c, project.ID, delta)
+	}
+	result, err := h.Artifacts.Apply(c.Param("id"), userID, req.Paths, allow)
+	if errors.Is(err, runartifacts.ErrStorageLimit) {
+		return // quota response already written
+	}
+	if err != nil {
+		h.writeArtifactError(c, err)
+		return
+	}
+	if h.UsageTracker != nil && result.StorageDelta != 0 {
+		if err := h.UsageTracker.RecordStorageChange(c.Request.Context(), userID, &project.ID, result.StorageDelta); err != nil {
+			log.Printf("usage tracker: failed to record storage change for user %d: %v", userID, err)
+		}
+	}
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"execution_id": c.Param("id"),
+			"project_id":   project.ID,
+			"applied":      result.Applied,
+			"skipped":      result.Skipped,
+		},
+	})
+}
+
+func (h *ExecutionHandler) writeArtifactError(c *gin.Context, err error) {
+	if errors.Is(err, runartifacts.ErrNotFound) {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "NOT_FOUND",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, StandardResponse{
+		Success: false,
+		Error:   "Failed to load execution artifacts",
+		Code:    "DATABASE_ERROR",
+	})
+}
@@ -3,6 +3,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -46,6 +47,7 @@ func (h *ExportHandler) ExportToGitHub(c *gin.Context) {
 		IncludeGitignore  bool   `json:"include_gitignore"`
 		IncludeDockerfile bool   `json:"include_dockerfile"`
 		IncludeReadme     bool   `json:"include_readme"`
+		SignCommits       bool   `json:"sign_commits"` // sign with the GIT_SIGNING_KEY secret
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -143,8 +145,16 @@ func (h *ExportHandler) ExportToGitHub(c *gin.Context) {
 		description,
 		token,
 		req.IsPrivate,
+		req.SignCommits,
 	)
 	if err != nil {
+		if errors.Is(err, git.ErrNoSigningKey) || errors.Is(err, git.ErrUnsupportedSigner) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Export failed: " + err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Export failed: " + err.Error(),
@@ -393,6 +393,9 @@ func (h *Handler) UpdateFile(c *gin.Context) {
 		updates["path"] = *req.Path
 	}
 
+	// Describe the change for the activity feed before file is overwritten
+	fileEvent := fileActivityEvent(&file, userID, user.Username, req.Content, req.Path, req.Name)
+
 	// Update file
 	if err := h.DB.Model(&file).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
@@ -402,6 +405,7 @@ func (h *Handler) UpdateFile(c *gin.Context) {
 		})
 		return
 	}
+	recordActivity(c, h.Activity, fileEvent)
 
 	response := map[string]interface{}{
 		"message": "File updated successfully",
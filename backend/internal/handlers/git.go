@@ -3,10 +3,12 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"apex-build/internal/git"
+	"apex-build/internal/restorepoints"
 	"apex-build/internal/secrets"
 	"apex-build/pkg/models"
 
@@ -19,6 +21,10 @@ type GitHandler struct {
 	db             *gorm.DB
 	gitService     *git.GitService
 	secretsManager *secrets.SecretsManager
+
+	conflictAssistant ConflictAssistant
+	issueSolver       ConflictAssistant
+	restorePoints     RestorePointSnapshotter
 }
 
 var errProjectAccessDenied = errors.New("project access denied")
@@ -43,6 +49,12 @@ func NewGitHandler(db *gorm.DB, gitService *git.GitService, secretsManager *secr
 	}
 }
 
+// SetRestorePoints makes pulls and syncs take a project restore point
+// before remote changes are written into the project.
+func (h *GitHandler) SetRestorePoints(points RestorePointSnapshotter) {
+	h.restorePoints = points
+}
+
 // ConnectRepository connects a project to a remote repository
 // POST /api/v1/git/connect
 func (h *GitHandler) ConnectRepository(c *gin.Context) {
@@ -352,7 +364,8 @@ func (h *GitHandler) Pull(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
 	var req struct {
-		ProjectID uint `json:"project_id" binding:"required"`
+		ProjectID uint   `json:"project_id" binding:"required"`
+		Strategy  string `json:"strategy"` // merge (default) or theirs
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -373,15 +386,46 @@ func (h *GitHandler) Pull(c *gin.Context) {
 	}
 
 	token := h.getGitToken(userID, req.ProjectID)
+	snapshotBeforeChange(h.db, h.restorePoints, req.ProjectID, userID, restorepoints.ReasonImport, "Before git pull")
+
+	// "theirs" overwrites the project with the remote branch
+	if req.Strategy == "theirs" {
+		if err := h.gitService.Pull(c.Request.Context(), req.ProjectID, token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Pulled successfully",
+		})
+		return
+	}
 
-	if err := h.gitService.Pull(c.Request.Context(), req.ProjectID, token); err != nil {
+	result, err := h.gitService.SyncFromRemote(c.Request.Context(), req.ProjectID, token, "")
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if len(result.Conflicts) > 0 {
+		conflicts, err := h.gitService.GetConflictDetails(c.Request.Context(), req.ProjectID, token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     fmt.Sprintf("Pull stopped on %d conflicting file(s)", len(conflicts)),
+			"code":      "MERGE_CONFLICT",
+			"result":    result,
+			"conflicts": conflicts,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Pulled successfully",
+		"result":  result,
 	})
 }
 
@@ -580,6 +624,12 @@ func (h *GitHandler) DisconnectRepository(c *gin.Context) {
 		return
 	}
 
+	// Remove the auto-sync webhook while the token is still available
+	if repo, err := h.gitService.GetRepository(c.Request.Context(), uint(projectID)); err == nil && repo.WebhookID != 0 {
+		h.gitService.DisableAutoSync(c.Request.Context(), uint(projectID), h.getGitToken(userID, uint(projectID)))
+	}
+	h.gitService.ClearSyncState(c.Request.Context(), uint(projectID))
+
 	// Delete repository record
 	if err := h.db.Where("project_id = ?", projectID).Delete(&git.Repository{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect repository"})
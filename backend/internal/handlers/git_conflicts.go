@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/git"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ConflictAssistant produces AI merge suggestions; *ai.AIRouter satisfies it.
+type ConflictAssistant interface {
+	Generate(ctx context.Context, req *ai.AIRequest) (*ai.AIResponse, error)
+}
+
+// SetConflictAssistant enables AI-assisted conflict resolution
+func (h *GitHandler) SetConflictAssistant(assistant ConflictAssistant) {
+	h.conflictAssistant = assistant
+}
+
+// GetConflicts returns every unresolved conflict with base, ours, theirs
+// and the conflicting hunks
+// GET /api/v1/git/conflicts/:projectId
+func (h *GitHandler) GetConflicts(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	token := h.getGitToken(c.GetUint("user_id"), projectID)
+	conflicts, err := h.gitService.GetConflictDetails(c.Request.Context(), projectID, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"conflicts": conflicts,
+		"count":     len(conflicts),
+	})
+}
+
+// ResolveConflict accepts ours, theirs or manual content for one file
+// POST /api/v1/git/conflicts/:projectId/resolve
+func (h *GitHandler) ResolveConflict(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Path       string  `json:"path" binding:"required"`
+		Resolution string  `json:"resolution" binding:"required"`
+		Content    *string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	content := ""
+	if req.Resolution == git.ResolveManual {
+		if req.Content == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content is required for a manual resolution"})
+			return
+		}
+		content = *req.Content
+		if strings.Contains(content, "<<<<<<< ours\n") && strings.Contains(content, ">>>>>>> theirs") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content still contains conflict markers"})
+			return
+		}
+	}
+
+	token := h.getGitToken(c.GetUint("user_id"), projectID)
+	conflict, err := h.gitService.ResolveConflict(c.Request.Context(), projectID, req.Path, req.Resolution, content, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, git.ErrConflictNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No open conflict for this path"})
+		case errors.Is(err, git.ErrInvalidResolution):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	remaining, err := h.gitService.GetSyncConflicts(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"conflict":  conflict,
+		"remaining": len(remaining),
+	})
+}
+
+// SuggestConflictResolution asks the Solver agent for a merged version of a
+// conflicted file. Nothing is written; the caller can submit the suggestion
+// as a manual resolution. The call is charged to the caller like any other
+// AI request.
+// POST /api/v1/git/conflicts/:projectId/suggest
+func (h *GitHandler) SuggestConflictResolution(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+	if h.conflictAssistant == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI conflict resolution is not available"})
+		return
+	}
+
+	var req struct {
+		Path string `json:"path" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	detail, err := h.gitService.GetConflictDetail(c.Request.Context(), projectID, req.Path, h.getGitToken(userID, projectID))
+	if err != nil {
+		if errors.Is(err, git.ErrConflictNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No open conflict for this path"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	resp, err := h.conflictAssistant.Generate(ctx, &ai.AIRequest{
+		ID:          uuid.New().String(),
+		Capability:  ai.CapabilityDebugging,
+		Prompt:      conflictResolutionPrompt(detail),
+		Code:        detail.Merged,
+		Temperature: 0.1,
+		UserID:      strconv.Itoa(int(userID)),
+		ProjectID:   strconv.Itoa(int(projectID)),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		respondAIFailure(c, err, "AI suggestion failed. Please try again.")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"path":       detail.Path,
+		"suggestion": stripMarkdownFence(resp.Content),
+		"provider":   resp.Provider,
+	})
+}
+
+// CommitConflictResolution commits files resolved in favour of the project
+// copy once no conflicts remain
+// POST /api/v1/git/conflicts/:projectId/commit
+func (h *GitHandler) CommitConflictResolution(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := h.getGitToken(c.GetUint("user_id"), projectID)
+	commit, err := h.gitService.CommitResolution(c.Request.Context(), projectID, req.Message, token)
+	if err != nil {
+		if errors.Is(err, git.ErrUnresolvedConflicts) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Resolve every conflict before committing the merge", "code": "MERGE_CONFLICT"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Merge committed"
+	if commit == nil {
+		message = "Nothing to commit; the project already matches the remote"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"commit":  commit,
+		"message": message,
+	})
+}
+
+func conflictResolutionPrompt(detail *git.ConflictDetail) string {
+	var b strings.Builder
+	b.WriteString("You are the APEX Solver agent resolving a git merge conflict in ")
+	b.WriteString(detail.Path)
+	b.WriteString(".\n\nThe file below is the merge result with conflict markers. Each region between ")
+	b.WriteString("<<<<<<< ours and ======= is the APEX project's version; each region between ======= and ")
+	b.WriteString(">>>>>>> theirs is the version pushed to the remote branch.\n\n")
+	for _, hunk := range detail.Hunks {
+		fmt.Fprintf(&b, "Conflict %d (base line %d) originally read:\n%s\n", hunk.Index+1, hunk.BaseStart, hunk.Base)
+	}
+	b.WriteString("\nCombine both sides so neither change is lost, keeping the code valid. ")
+	b.WriteString("Reply with the complete resolved file only, without conflict markers or commentary.")
+	return b.String()
+}
+
+// stripMarkdownFence unwraps a reply that arrives as one fenced code block
+func stripMarkdownFence(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") {
+		return content
+	}
+	body := strings.TrimSuffix(trimmed, "```")
+	if newline := strings.Index(body, "\n"); newline >= 0 {
+		body = body[newline+1:]
+	} else {
+		return content
+	}
+	return strings.TrimSuffix(body, "\n") + "\n"
+}
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/git"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// Files handed to the Solver as context for an issue build
+	maxIssueContextFiles = 12
+	maxIssueContextBytes = 96 * 1024
+	maxIssueFileBytes    = 32 * 1024
+	// maxIssueTextChars bounds the issue body and comments in the prompt
+	maxIssueTextChars = 12000
+	issueBuildTimeout = 3 * time.Minute
+)
+
+// SetIssueSolver enables building changes from GitHub issues
+func (h *GitHandler) SetIssueSolver(solver ConflictAssistant) {
+	h.issueSolver = solver
+}
+
+// BuildFromIssue turns a GitHub issue on the project's connected repository
+// into a change-request build: the issue and its comments become the change
+// description and the files it most likely concerns become context. Anyone
+// who can open an issue writes that description, so the Solver's edits are
+// never applied to the project; they are committed to a new branch and a
+// pull request linking back to the issue is opened for review.
+// POST /api/v1/git/issues/:projectId/build
+func (h *GitHandler) BuildFromIssue(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+	if h.issueSolver == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Building from issues is not available"})
+		return
+	}
+
+	var req struct {
+		IssueURL   string `json:"issue_url" binding:"required"`
+		BaseBranch string `json:"base_branch"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	token := h.getGitToken(userID, projectID)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), issueBuildTimeout)
+	defer cancel()
+
+	repo, issue, err := h.gitService.IssueForProject(ctx, projectID, req.IssueURL, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, git.ErrInvalidIssueURL), errors.Is(err, git.ErrIssueRepoMismatch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, git.ErrIssueNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Connect a GitHub repository to this project first"})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	var files []models.File
+	if err := h.db.Where("project_id = ? AND type = ? AND is_binary = ?", projectID, "file", false).Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load project files"})
+		return
+	}
+	contextFiles := selectIssueContextFiles(issue, files)
+
+	build := &git.IssueBuild{
+		ProjectID:    projectID,
+		UserID:       userID,
+		IssueNumber:  issue.Number,
+		IssueURL:     issue.URL,
+		IssueTitle:   issue.Title,
+		ContextFiles: issueFilePaths(contextFiles),
+	}
+	fail := func(status int, message string, err error) {
+		build.Status = git.IssueBuildFailed
+		build.Error = err.Error()
+		_ = h.gitService.SaveIssueBuild(c.Request.Context(), build)
+		c.JSON(status, gin.H{"error": message, "build": build})
+	}
+
+	resp, err := h.issueSolver.Generate(ctx, &ai.AIRequest{
+		ID:          uuid.New().String(),
+		Capability:  ai.CapabilityCodeGeneration,
+		Prompt:      issueBuildPrompt(repo, issue, contextFiles),
+		Temperature: 0.2,
+		UserID:      strconv.Itoa(int(userID)),
+		ProjectID:   strconv.Itoa(int(projectID)),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		build.Status = git.IssueBuildFailed
+		build.Error = err.Error()
+		_ = h.gitService.SaveIssueBuild(c.Request.Context(), build)
+		respondAIFailure(c, err, "AI change request failed. Please try again.")
+		return
+	}
+	build.Provider = string(resp.Provider)
+
+	summary, changes, err := parseIssueBuildChanges(resp.Content)
+	if err != nil {
+		fail(http.StatusBadGateway, "The AI reply could not be applied", err)
+		return
+	}
+	changes = matchExistingPaths(changes, files)
+	build.Summary = summary
+	if len(changes) == 0 {
+		build.Status = git.IssueBuildNoChanges
+		build.ChangedFiles = []string{}
+		_ = h.gitService.SaveIssueBuild(c.Request.Context(), build)
+		c.JSON(http.StatusOK, gin.H{"success": true, "build": build, "message": "No changes were needed for this issue"})
+		return
+	}
+
+	build.ChangedFiles = sortedIssuePaths(changes)
+	if err := h.openIssuePullRequest(ctx, repo, issue, build, req.BaseBranch, changes, token); err != nil {
+		fail(http.StatusBadGateway, "The pull request for the changes could not be opened", err)
+		return
+	}
+	build.Status = git.IssueBuildCompleted
+	if err := h.gitService.SaveIssueBuild(c.Request.Context(), build); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "The pull request was opened but the build record could not be saved"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "build": build, "issue": issue})
+}
+
+// openIssuePullRequest commits the proposed changes to a new branch, without
+// touching the project's files, and opens a pull request that closes the
+// issue
+func (h *GitHandler) openIssuePullRequest(ctx context.Context, repo *git.Repository, issue *git.Issue, build *git.IssueBuild, base string, changes map[string]string, token string) error {
+	if base == "" {
+		base = repo.Branch
+	}
+	branch := fmt.Sprintf("apex/issue-%d-%s", issue.Number, strconv.FormatInt(time.Now().Unix(), 36))
+	if _, err := h.gitService.CreateBranch(ctx, repo.ProjectID, branch, base, token); err != nil {
+		return err
+	}
+	build.Branch = branch
+
+	message := fmt.Sprintf("Fix #%d: %s", issue.Number, issue.Title)
+	if _, err := h.gitService.CommitContentsToBranch(ctx, repo.ProjectID, branch, message, changes, token); err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Closes #%d\n\n", issue.Number)
+	if build.Summary != "" {
+		body.WriteString(build.Summary)
+		body.WriteString("\n\n")
+	}
+	body.WriteString("Changed files:\n")
+	for _, file := range build.ChangedFiles {
+		fmt.Fprintf(&body, "- `%s`\n", file)
+	}
+	fmt.Fprintf(&body, "\nBuilt by APEX.BUILD from %s", issue.URL)
+
+	pr, err := h.gitService.CreatePullRequest(ctx, repo.ProjectID, message, body.String(), branch, base, token)
+	if err != nil {
+		return err
+	}
+	build.PullRequestNumber = pr.Number
+	build.PullRequestURL = pr.URL
+	return nil
+}
+
+// ListIssueBuilds returns the project's recent issue builds
+// GET /api/v1/git/issues/:projectId/builds
+func (h *GitHandler) ListIssueBuilds(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	builds, err := h.gitService.ListIssueBuilds(c.Request.Context(), projectID, 50)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load issue builds"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "builds": builds})
+}
+
+var (
+	issuePathPattern  = regexp.MustCompile(`[A-Za-z0-9_./-]+\.[A-Za-z0-9]{1,6}`)
+	issueIdentPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{3,}`)
+)
+
+// issueStopWords are common words that say nothing about which files an
+// issue concerns
+var issueStopWords = map[string]bool{
+	"that": true, "this": true, "with": true, "when": true, "from": true, "have": true,
+	"should": true, "would": true, "could": true, "there": true, "which": true, "what": true,
+	"after": true, "before": true, "because": true, "into": true, "also": true, "some": true,
+	"page": true, "error": true, "issue": true, "expected": true, "actual": true, "steps": true,
+	"reproduce": true, "behavior": true, "behaviour": true, "version": true, "using": true,
+	"does": true, "doesn": true, "work": true, "works": true, "just": true, "like": true,
+	"then": true, "they": true, "them": true, "their": true, "were": true, "been": true,
+	"will": true, "only": true, "need": true, "thanks": true, "please": true, "http": true, "https": true,
+}
+
+func issueText(issue *git.Issue) string {
+	var b strings.Builder
+	b.WriteString(issue.Title)
+	b.WriteString("\n")
+	b.WriteString(issue.Body)
+	for _, comment := range issue.Comments {
+		b.WriteString("\n")
+		b.WriteString(comment.Body)
+	}
+	return b.String()
+}
+
+// selectIssueContextFiles ranks project files by how strongly the issue
+// points at them: paths and file names it mentions first, then files whose
+// path or content share its identifiers.
+func selectIssueContextFiles(issue *git.Issue, files []models.File) []models.File {
+	text := issueText(issue)
+	lower := strings.ToLower(text)
+
+	mentioned := map[string]bool{}
+	for _, match := range issuePathPattern.FindAllString(text, -1) {
+		mentioned[strings.ToLower(strings.Trim(match, "./"))] = true
+	}
+	terms := map[string]bool{}
+	for _, match := range issueIdentPattern.FindAllString(text, -1) {
+		term := strings.ToLower(match)
+		if !issueStopWords[term] {
+			terms[term] = true
+		}
+	}
+
+	type scored struct {
+		file  models.File
+		score int
+	}
+	var ranked []scored
+	for _, file := range files {
+		if file.Content == "" || len(file.Content) > maxIssueFileBytes {
+			continue
+		}
+		filePath := strings.ToLower(strings.TrimPrefix(file.Path, "/"))
+		score := 0
+		if mentioned[filePath] || strings.Contains(lower, filePath) {
+			score += 20
+		} else if base := path.Base(filePath); mentioned[base] {
+			score += 10
+		}
+		content := strings.ToLower(file.Content)
+		for term := range terms {
+			if strings.Contains(filePath, term) {
+				score += 3
+			} else if strings.Contains(content, term) {
+				score++
+			}
+		}
+		if score > 0 {
+			ranked = append(ranked, scored{file, score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].file.Path < ranked[j].file.Path
+	})
+
+	var selected []models.File
+	total := 0
+	for _, entry := range ranked {
+		if len(selected) == maxIssueContextFiles {
+			break
+		}
+		if total+len(entry.file.Content) > maxIssueContextBytes {
+			continue
+		}
+		total += len(entry.file.Content)
+		selected = append(selected, entry.file)
+	}
+	return selected
+}
+
+func issueBuildPrompt(repo *git.Repository, issue *git.Issue, files []models.File) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are the APEX Solver agent implementing GitHub issue #%d of %s/%s in the imported project.\n\n", issue.Number, repo.RepoOwner, repo.RepoName)
+
+	var discussion strings.Builder
+	fmt.Fprintf(&discussion, "Title: %s\n", issue.Title)
+	if len(issue.Labels) > 0 {
+		fmt.Fprintf(&discussion, "Labels: %s\n", strings.Join(issue.Labels, ", "))
+	}
+	fmt.Fprintf(&discussion, "\n%s\n", strings.TrimSpace(issue.Body))
+	for _, comment := range issue.Comments {
+		fmt.Fprintf(&discussion, "\nComment by %s:\n%s\n", comment.Author, strings.TrimSpace(comment.Body))
+	}
+	issueBody := discussion.String()
+	if len(issueBody) > maxIssueTextChars {
+		issueBody = issueBody[:maxIssueTextChars] + "\n... (truncated)"
+	}
+	b.WriteString("## Issue\n")
+	b.WriteString(issueBody)
+
+	b.WriteString("\n## Relevant project files\n")
+	if len(files) == 0 {
+		b.WriteString("(no files matched the issue; create new files only if the issue requires them)\n")
+	}
+	for _, file := range files {
+		fmt.Fprintf(&b, "\n=== FILE: %s ===\n%s\n=== END FILE ===\n", file.Path, file.Content)
+	}
+
+	b.WriteString("\n## Instructions\n")
+	b.WriteString("Make the smallest change that resolves the issue, matching the project's existing style. ")
+	b.WriteString("Start your reply with a line \"SUMMARY: \" describing the change in one or two sentences. ")
+	b.WriteString("Then, for every file you change or create, output its complete new content between ")
+	b.WriteString("\"=== FILE: <path> ===\" and \"=== END FILE ===\" lines, without markdown fences. ")
+	b.WriteString("Do not output files you leave unchanged. If no change is needed, output only the summary.")
+	return b.String()
+}
+
+// parseIssueBuildChanges reads the summary and complete files from a Solver
+// reply. Paths must stay inside the project.
+func parseIssueBuildChanges(reply string) (string, map[string]string, error) {
+	summary := ""
+	changes := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(reply, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if rest, ok := strings.CutPrefix(line, "SUMMARY:"); ok && summary == "" {
+			summary = strings.TrimSpace(rest)
+			continue
+		}
+		if !strings.HasPrefix(line, "=== FILE:") || !strings.HasSuffix(line, "===") {
+			continue
+		}
+		filePath := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "=== FILE:"), "==="))
+		cleaned := path.Clean(strings.TrimPrefix(filePath, "/"))
+		if filePath == "" || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return "", nil, fmt.Errorf("invalid file path %q", filePath)
+		}
+
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "=== END FILE ===" {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return "", nil, fmt.Errorf("file %s is not terminated", cleaned)
+		}
+		content := strings.Join(lines[i+1:end], "\n") + "\n"
+		changes[cleaned] = stripMarkdownFence(content)
+		i = end
+	}
+	return summary, changes, nil
+}
+
+// matchExistingPaths maps cleaned reply paths back to the project's own
+// spelling (e.g. a leading slash) so edits overwrite rather than duplicate.
+func matchExistingPaths(changes map[string]string, files []models.File) map[string]string {
+	existing := make(map[string]string, len(files))
+	for _, file := range files {
+		existing[strings.TrimPrefix(file.Path, "/")] = file.Path
+	}
+	matched := make(map[string]string, len(changes))
+	for filePath, content := range changes {
+		if original, ok := existing[filePath]; ok {
+			filePath = original
+		}
+		matched[filePath] = content
+	}
+	return matched
+}
+
+func issueFilePaths(files []models.File) []string {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func sortedIssuePaths(changes map[string]string) []string {
+	paths := make([]string, 0, len(changes))
+	for filePath := range changes {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"testing"
+
+	"apex-build/internal/git"
+	"apex-build/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectIssueContextFilesRanksMentionedFiles(t *testing.T) {
+	issue := &git.Issue{
+		Title: "Checkout total ignores discount",
+		Body:  "In `src/cart/Checkout.jsx` the total is computed without applyDiscount.",
+		Comments: []git.IssueComment{
+			{Body: "The discount helper lives in pricing.js"},
+		},
+	}
+	files := []models.File{
+		{Path: "src/cart/Checkout.jsx", Content: "export function Checkout() { return total }"},
+		{Path: "src/lib/pricing.js", Content: "export function applyDiscount(total, code) {}"},
+		{Path: "src/lib/discount-codes.js", Content: "export const codes = []"},
+		{Path: "README.md", Content: "A shop"},
+		{Path: "src/huge.js", Content: string(make([]byte, maxIssueFileBytes+1)) + "applyDiscount"},
+	}
+
+	selected := issueFilePaths(selectIssueContextFiles(issue, files))
+	require.Equal(t, []string{"src/cart/Checkout.jsx", "src/lib/pricing.js", "src/lib/discount-codes.js"}, selected)
+}
+
+func TestParseIssueBuildChanges(t *testing.T) {
+	reply := "SUMMARY: Apply the discount before computing the total.\n\n" +
+		"=== FILE: src/cart/Checkout.jsx ===\n```jsx\nexport const a = 1\n```\n=== END FILE ===\n" +
+		"=== FILE: /src/lib/new.js ===\nexport const b = 2\n=== END FILE ===\n"
+
+	summary, changes, err := parseIssueBuildChanges(reply)
+	require.NoError(t, err)
+	require.Equal(t, "Apply the discount before computing the total.", summary)
+	require.Equal(t, map[string]string{
+		"src/cart/Checkout.jsx": "export const a = 1\n",
+		"src/lib/new.js":        "export const b = 2\n",
+	}, changes)
+
+	// Existing files keep their stored spelling
+	matched := matchExistingPaths(changes, []models.File{{Path: "/src/lib/new.js"}})
+	require.Contains(t, matched, "/src/lib/new.js")
+	require.Contains(t, matched, "src/cart/Checkout.jsx")
+
+	_, _, err = parseIssueBuildChanges("=== FILE: ../etc/passwd ===\nx\n=== END FILE ===")
+	require.Error(t, err)
+	_, _, err = parseIssueBuildChanges("=== FILE: a.js ===\nunterminated")
+	require.Error(t, err)
+
+	summary, changes, err = parseIssueBuildChanges("SUMMARY: Already fixed upstream.")
+	require.NoError(t, err)
+	require.Equal(t, "Already fixed upstream.", summary)
+	require.Empty(t, changes)
+}
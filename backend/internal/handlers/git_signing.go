@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"apex-build/internal/git"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCommitSigning reports whether platform commits are signed and which
+// public key to register with GitHub
+// GET /api/v1/git/signing/:projectId
+func (h *GitHandler) GetCommitSigning(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	repo, err := h.gitService.GetRepository(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not connected"})
+		return
+	}
+
+	response := gin.H{
+		"success": true,
+		"enabled": repo.SignCommits,
+		"key":     nil,
+	}
+	key, err := h.gitService.GetSigningKey(c.Request.Context(), projectID)
+	if err == nil {
+		response["key"] = key
+	} else if !errors.Is(err, git.ErrNoSigningKey) {
+		response["key_error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SetCommitSigning turns commit signing on or off. The key comes from the
+// GIT_SIGNING_KEY secret (and GIT_SIGNING_KEY_PASSPHRASE when protected).
+// PUT /api/v1/git/signing/:projectId
+func (h *GitHandler) SetCommitSigning(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo, err := h.gitService.SetCommitSigning(c.Request.Context(), projectID, *req.Enabled)
+	if err != nil {
+		if errors.Is(err, git.ErrNoSigningKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store a GPG or SSH private key as the GIT_SIGNING_KEY secret first"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"repository": repo,
+	})
+}
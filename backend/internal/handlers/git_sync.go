@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/git"
+	"apex-build/internal/restorepoints"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookPayload matches GitHub's documented webhook payload cap
+const maxWebhookPayload = 25 << 20
+
+// GetSyncStatus returns the auto-sync state and outstanding conflicts
+// GET /api/v1/git/sync/:projectId
+func (h *GitHandler) GetSyncStatus(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	repo, err := h.gitService.GetRepository(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not connected"})
+		return
+	}
+
+	conflicts, err := h.gitService.GetSyncConflicts(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sync conflicts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"auto_sync":       repo.AutoSync,
+		"branch":          repo.Branch,
+		"status":          repo.SyncStatus,
+		"error":           repo.SyncError,
+		"last_sync":       repo.LastSync,
+		"last_synced_sha": repo.LastSyncedSHA,
+		"conflicts":       conflicts,
+	})
+}
+
+// SetAutoSync turns webhook-driven sync on or off
+// PUT /api/v1/git/sync/:projectId
+func (h *GitHandler) SetAutoSync(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := h.getGitToken(c.GetUint("user_id"), projectID)
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A GitHub token with admin:repo_hook access is required for auto-sync"})
+		return
+	}
+
+	var (
+		repo *git.Repository
+		err  error
+	)
+	if *req.Enabled {
+		repo, err = h.gitService.EnableAutoSync(c.Request.Context(), projectID, token, gitWebhookURL(c, projectID))
+	} else {
+		repo, err = h.gitService.DisableAutoSync(c.Request.Context(), projectID, token)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"repository": repo,
+	})
+}
+
+// SyncNow runs a conflict-aware sync of the connected branch immediately
+// POST /api/v1/git/sync/:projectId
+func (h *GitHandler) SyncNow(c *gin.Context) {
+	projectID, ok := h.syncProject(c)
+	if !ok {
+		return
+	}
+
+	token := h.getGitToken(c.GetUint("user_id"), projectID)
+	snapshotBeforeChange(h.db, h.restorePoints, projectID, c.GetUint("user_id"), restorepoints.ReasonImport, "Before git sync")
+	result, err := h.gitService.SyncFromRemote(c.Request.Context(), projectID, token, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// HandleGitHubWebhook receives push events for auto-synced repositories.
+// It is unauthenticated; requests are verified against the per-repository
+// webhook secret.
+// POST /api/v1/git/webhooks/github/:projectId
+func (h *GitHandler) HandleGitHubWebhook(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookPayload+1))
+	if err != nil || len(body) > maxWebhookPayload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	repo, err := h.gitService.GetRepository(c.Request.Context(), uint(projectID))
+	if err != nil || repo.WebhookSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not registered"})
+		return
+	}
+	if !git.VerifyWebhookSignature(repo.WebhookSecret, body, c.GetHeader("X-Hub-Signature-256")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	switch c.GetHeader("X-GitHub-Event") {
+	case "ping":
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "pong"})
+		return
+	case "push":
+	default:
+		c.JSON(http.StatusOK, gin.H{"success": true, "ignored": true})
+		return
+	}
+
+	var push struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Deleted bool   `json:"deleted"`
+	}
+	if err := json.Unmarshal(body, &push); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+	if !repo.AutoSync || push.Deleted || push.Ref != "refs/heads/"+repo.Branch {
+		c.JSON(http.StatusOK, gin.H{"success": true, "ignored": true})
+		return
+	}
+
+	var project models.Project
+	if err := h.db.Select("id", "owner_id").First(&project, repo.ProjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	snapshotBeforeChange(h.db, h.restorePoints, repo.ProjectID, project.OwnerID, restorepoints.ReasonImport, "Before auto-sync")
+	h.gitService.QueueSync(repo.ProjectID, h.getGitToken(project.OwnerID, repo.ProjectID), push.After)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success":    true,
+		"queued":     true,
+		"commit_sha": push.After,
+	})
+}
+
+// syncProject parses :projectId and checks the caller owns the project
+func (h *GitHandler) syncProject(c *gin.Context) (uint, bool) {
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, false
+	}
+
+	if _, err := h.ensureProjectOwner(uint(projectID), c.GetUint("user_id")); err != nil {
+		if errors.Is(err, errProjectAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return 0, false
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return 0, false
+	}
+	return uint(projectID), true
+}
+
+// gitWebhookURL is the public callback GitHub posts push events to.
+func gitWebhookURL(c *gin.Context, projectID uint) string {
+	return fmt.Sprintf("%s/api/v1/git/webhooks/github/%d", publicAPIBaseURL(c), projectID)
+}
+
+// publicAPIBaseURL is the API origin external callers reach.
+// PUBLIC_API_URL overrides the request host when the API sits behind a proxy.
+func publicAPIBaseURL(c *gin.Context) string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		if forwardedProto := strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Proto"), ",")[0]); forwardedProto != "" {
+			scheme = forwardedProto
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base
+}
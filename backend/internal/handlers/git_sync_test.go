@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"apex-build/internal/git"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGitHubWebhookRequiresSignatureAndConnectedBranch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &git.Repository{}))
+
+	user := models.User{Username: "hooked", Email: "hooked@example.com", PasswordHash: "hashed-password"}
+	require.NoError(t, db.Create(&user).Error)
+	project := models.Project{Name: "Hooked", Language: "javascript", OwnerID: user.ID}
+	require.NoError(t, db.Create(&project).Error)
+	require.NoError(t, db.Create(&git.Repository{
+		ProjectID:     project.ID,
+		Provider:      "github",
+		RepoOwner:     "acme",
+		RepoName:      "site",
+		Branch:        "main",
+		IsConnected:   true,
+		AutoSync:      true,
+		WebhookID:     7,
+		WebhookSecret: "hook-secret",
+	}).Error)
+
+	handler := NewGitHandler(db, git.NewGitService(db), nil)
+	router := gin.New()
+	router.POST("/git/webhooks/github/:projectId", handler.HandleGitHubWebhook)
+
+	send := func(event, body, secret string) *httptest.ResponseRecorder {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req := httptest.NewRequest(http.MethodPost, "/git/webhooks/github/"+strconv.Itoa(int(project.ID)), strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("push", `{"ref":"refs/heads/main","after":"abc"}`, "wrong-secret")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = send("ping", `{"zen":"hi"}`, "hook-secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = send("push", `{"ref":"refs/heads/feature","after":"abc"}`, "hook-secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"ignored":true`)
+}
@@ -11,9 +11,11 @@ import (
 	"strings"
 	"time"
 
+	"apex-build/internal/activity"
 	"apex-build/internal/ai"
 	"apex-build/internal/auth"
 	"apex-build/internal/middleware"
+	"apex-build/internal/pagination"
 	"apex-build/internal/payments"
 	"apex-build/internal/spend"
 	"apex-build/internal/websocket"
@@ -30,6 +32,7 @@ type Handler struct {
 	AuthService  *auth.AuthService
 	WSHub        *websocket.Hub
 	SpendTracker *spend.SpendTracker
+	Activity     *activity.Service
 }
 
 // NewHandler creates a new handler instance
@@ -54,7 +57,8 @@ type StandardResponse struct {
 // PaginatedResponse represents a paginated API response
 type PaginatedResponse struct {
 	StandardResponse
-	Pagination *PaginationInfo `json:"pagination,omitempty"`
+	Pagination *PaginationInfo  `json:"pagination,omitempty"`
+	PageInfo   *pagination.Info `json:"page_info,omitempty"`
 }
 
 // PaginationInfo contains pagination metadata
@@ -129,7 +133,7 @@ func (h *Handler) Register(c *gin.Context) {
 	user.CreditBalance = payments.FreeSignupTrialCreditsUSD
 
 	// Generate tokens
-	tokens, err := h.AuthService.GenerateTokens(user)
+	tokens, err := h.AuthService.GenerateTokensWithMetadata(user, auth.RequestMetadata(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
@@ -215,7 +219,7 @@ func (h *Handler) Login(c *gin.Context) {
 	}
 
 	// Generate tokens
-	tokens, err := h.AuthService.GenerateTokens(&user)
+	tokens, err := h.AuthService.GenerateTokensWithMetadata(&user, auth.RequestMetadata(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
@@ -284,7 +288,7 @@ func (h *Handler) RefreshToken(c *gin.Context) {
 	}
 
 	// Generate new tokens
-	tokens, err := h.AuthService.RefreshTokens(refreshToken, nil)
+	tokens, err := h.AuthService.RefreshSession(refreshToken, auth.RequestMetadata(c))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, StandardResponse{
 			Success: false,
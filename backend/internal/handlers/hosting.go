@@ -6,13 +6,16 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"apex-build/internal/hosting"
+	"apex-build/internal/logdrain"
 	"apex-build/internal/origins"
+	"apex-build/internal/pagination"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -22,8 +25,10 @@ import (
 
 // HostingHandler handles native hosting endpoints
 type HostingHandler struct {
-	db      *gorm.DB
-	service *hosting.HostingService
+	db          *gorm.DB
+	service     *hosting.HostingService
+	errorSolver ErrorSolver
+	drains      *logdrain.Manager
 }
 
 // NewHostingHandler creates a new hosting handler
@@ -180,10 +185,19 @@ func (h *HostingHandler) GetDeployments(c *gin.Context) {
 	}
 
 	// Get pagination params
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	list, err := pagination.Parse(c, hosting.DeploymentListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	deployments, total, err := h.service.GetProjectDeployments(uint(projectID), page, limit)
+	deployments, pageInfo, total, err := h.service.ListProjectDeployments(uint(projectID), list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	selected, err := pagination.SelectFields(deployments, list.Fields)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -191,10 +205,11 @@ func (h *HostingHandler) GetDeployments(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":     true,
-		"deployments": deployments,
+		"deployments": selected,
 		"total":       total,
-		"limit":       limit,
+		"limit":       list.Limit,
 		"page":        page,
+		"page_info":   pageInfo,
 	})
 }
 
@@ -409,6 +424,10 @@ func (h *HostingHandler) RestartDeployment(c *gin.Context) {
 	}
 
 	if err := h.service.RestartDeployment(deploymentID); err != nil {
+		if errors.Is(err, hosting.ErrRestartInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -789,6 +808,12 @@ func (h *HostingHandler) HandleDeploymentWebSocket(c *gin.Context) {
 			if err := conn.WriteJSON(msg); err != nil {
 				return
 			}
+			// Scale changes also go out as their own message type
+			if logEntry.Metadata["event"] == hosting.EventScale {
+				if err := conn.WriteJSON(map[string]interface{}{"type": "scale", "data": logEntry.Metadata}); err != nil {
+					return
+				}
+			}
 		case <-ticker.C:
 			// Send status update
 			deployment, err := h.service.GetDeployment(deploymentID)
@@ -798,10 +823,11 @@ func (h *HostingHandler) HandleDeploymentWebSocket(c *gin.Context) {
 			msg := map[string]interface{}{
 				"type": "status",
 				"data": map[string]interface{}{
-					"status":           deployment.Status,
-					"container_status": deployment.ContainerStatus,
-					"url":              deployment.URL,
-					"error_message":    deployment.ErrorMessage,
+					"status":            deployment.Status,
+					"container_status":  deployment.ContainerStatus,
+					"url":               deployment.URL,
+					"error_message":     deployment.ErrorMessage,
+					"current_instances": deployment.CurrentInstances,
 				},
 			}
 			if err := conn.WriteJSON(msg); err != nil {
@@ -932,8 +958,9 @@ func getAlwaysOnMessage(enabled bool) string {
 	return "Always-On disabled. Your deployment may sleep after 30 minutes of inactivity."
 }
 
-// RegisterHostingRoutes registers all hosting routes
-func (h *HostingHandler) RegisterHostingRoutes(router *gin.RouterGroup) {
+// RegisterHostingRoutes registers all hosting routes. aiMiddlewares, such as
+// the AI quota and budget checks, guard the routes that call a model.
+func (h *HostingHandler) RegisterHostingRoutes(router *gin.RouterGroup, aiMiddlewares ...gin.HandlerFunc) {
 	// Project deployment routes
 	router.POST("/projects/:id/deploy", h.StartDeployment)
 	router.GET("/projects/:id/deployments", h.GetDeployments)
@@ -952,6 +979,17 @@ func (h *HostingHandler) RegisterHostingRoutes(router *gin.RouterGroup) {
 	router.GET("/projects/:id/deployments/:deploymentId/always-on", h.GetAlwaysOnStatus)
 	router.PUT("/projects/:id/deployments/:deploymentId/always-on", h.SetAlwaysOn)
 
+	// Security headers and CSP violation reports
+	router.GET("/projects/:id/deployments/:deploymentId/security-headers", h.GetSecurityHeaders)
+	router.PUT("/projects/:id/deployments/:deploymentId/security-headers", h.UpdateSecurityHeaders)
+	router.GET("/projects/:id/deployments/:deploymentId/csp-reports", h.GetCSPViolations)
+
+	// Health check configuration and restart history
+	router.PUT("/projects/:id/deployments/:deploymentId/health-check", h.UpdateHealthCheck)
+	router.GET("/projects/:id/deployments/:deploymentId/events", h.GetDeploymentEvents)
+	router.GET("/projects/:id/deployments/:deploymentId/autoscale", h.GetAutoscale)
+	router.PUT("/projects/:id/deployments/:deploymentId/autoscale", h.UpdateAutoscale)
+
 	// Alternative hosting management routes (as specified)
 	// These provide a cleaner API for the frontend: /api/v1/hosting/:projectId/...
 	hostingRoutes := router.Group("/hosting")
@@ -968,6 +1006,29 @@ func (h *HostingHandler) RegisterHostingRoutes(router *gin.RouterGroup) {
 		hostingRoutes.POST("/:projectId/domains", h.AddCustomDomain)
 		hostingRoutes.POST("/:projectId/domains/:domainId/verify", h.VerifyCustomDomain)
 		hostingRoutes.DELETE("/:projectId/domains/:domainId", h.DeleteCustomDomain)
+
+		// Background worker processes (optional managed queue, scale-to-zero)
+		hostingRoutes.GET("/:projectId/workers", h.GetWorkers)
+		hostingRoutes.POST("/:projectId/workers", h.CreateWorker)
+		hostingRoutes.PATCH("/:projectId/workers/:workerId", h.UpdateWorker)
+		hostingRoutes.DELETE("/:projectId/workers/:workerId", h.DeleteWorker)
+		hostingRoutes.POST("/:projectId/workers/:workerId/restart", h.RestartWorker)
+		hostingRoutes.GET("/:projectId/workers/:workerId/logs", h.GetWorkerLogs)
+		hostingRoutes.GET("/:projectId/workers/:workerId/metrics", h.GetWorkerMetrics)
+
+		// Production error reports
+		hostingRoutes.GET("/:projectId/errors", h.GetErrorGroups)
+		hostingRoutes.GET("/:projectId/errors/:groupId", h.GetErrorGroup)
+		hostingRoutes.PATCH("/:projectId/errors/:groupId", h.UpdateErrorGroup)
+		solveHandlers := append(append([]gin.HandlerFunc{}, aiMiddlewares...), h.SolveErrorGroup)
+		hostingRoutes.POST("/:projectId/errors/:groupId/solve", solveHandlers...)
+
+		// Log drains (hosting and preview logs to external sinks)
+		hostingRoutes.GET("/:projectId/log-drains", h.GetLogDrains)
+		hostingRoutes.POST("/:projectId/log-drains", h.CreateLogDrain)
+		hostingRoutes.PATCH("/:projectId/log-drains/:drainId", h.UpdateLogDrain)
+		hostingRoutes.DELETE("/:projectId/log-drains/:drainId", h.DeleteLogDrain)
+		hostingRoutes.POST("/:projectId/log-drains/:drainId/test", h.TestLogDrain)
 	}
 }
 
@@ -0,0 +1,122 @@
+// Package handlers - Autoscaling handlers for native hosting
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"apex-build/internal/hosting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// autoscalePlans lists plans in order with the most instances each may run
+var autoscalePlans = []struct {
+	plan         string
+	maxInstances int
+}{
+	{"free", 1},
+	{"builder", 2},
+	{"pro", 5},
+	{"team", 10},
+	{"enterprise", 20},
+}
+
+// autoscaleInstanceLimit returns the most instances a plan may scale to
+func autoscaleInstanceLimit(planType string) int {
+	if planType == "owner" {
+		return autoscalePlans[len(autoscalePlans)-1].maxInstances
+	}
+	for _, p := range autoscalePlans {
+		if p.plan == planType {
+			return p.maxInstances
+		}
+	}
+	return 1
+}
+
+// autoscaleRequiredPlan returns the smallest plan that allows the instance count
+func autoscaleRequiredPlan(instances int) string {
+	for _, p := range autoscalePlans {
+		if p.maxInstances >= instances {
+			return p.plan
+		}
+	}
+	return "enterprise"
+}
+
+// UpdateAutoscale sets a deployment's instance bounds and scaling targets
+// PUT /api/v1/projects/:id/deployments/:deploymentId/autoscale
+func (h *HostingHandler) UpdateAutoscale(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+
+	var req hosting.AutoscaleConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.Enabled && !requirePaidBackendPlan(c, h.db, deployment.UserID, "Autoscaling") {
+		return
+	}
+	planType := currentSubscriptionType(c, h.db, deployment.UserID)
+	limit := autoscaleInstanceLimit(planType)
+	if req.MaxInstances > limit {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":         fmt.Sprintf("Your plan allows up to %d instances", limit),
+			"current_plan":  planType,
+			"max_instances": limit,
+			"required_plan": autoscaleRequiredPlan(req.MaxInstances),
+		})
+		return
+	}
+
+	updated, err := h.service.UpdateAutoscale(deployment.ID, req, limit)
+	if err != nil {
+		if errors.Is(err, hosting.ErrInvalidAutoscale) || errors.Is(err, hosting.ErrAutoscaleRequiresAlwaysOn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update autoscaling"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"autoscale":         hosting.AutoscaleConfigFor(updated),
+		"current_instances": updated.CurrentInstances,
+		"plan_max":          limit,
+	})
+}
+
+// GetAutoscale returns a deployment's scaling policy, its current load, the
+// last hour of load per minute and recent scale events
+// GET /api/v1/projects/:id/deployments/:deploymentId/autoscale
+func (h *HostingHandler) GetAutoscale(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	events, err := h.service.GetDeploymentEvents(deployment.ID, hosting.EventScale, 50)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load scale events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"autoscale":         hosting.AutoscaleConfigFor(deployment),
+		"current_instances": deployment.CurrentInstances,
+		"last_scaled_at":    deployment.LastScaledAt,
+		"plan_max":          autoscaleInstanceLimit(currentSubscriptionType(c, h.db, deployment.UserID)),
+		"load":              h.service.DeploymentLoad(deployment.ID, 2*time.Minute, now),
+		"history":           h.service.LoadHistory(deployment.ID, now.Add(-time.Hour)),
+		"events":            events,
+	})
+}
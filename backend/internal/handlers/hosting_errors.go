@@ -0,0 +1,368 @@
+// Package handlers - Error reporting HTTP handlers for native hosting
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxErrorReportBytes caps an error report request body, decompressed
+	maxErrorReportBytes = 1 << 20
+	// maxSolverSourceFiles and maxSolverSourceBytes bound the project source
+	// sent to the Solver with an error
+	maxSolverSourceFiles = 3
+	maxSolverSourceBytes = 20 << 10
+)
+
+// ErrorSolver diagnoses production errors; *ai.AIRouter satisfies it.
+type ErrorSolver interface {
+	Generate(ctx context.Context, req *ai.AIRequest) (*ai.AIResponse, error)
+}
+
+// SetErrorSolver enables Solver analysis of reported errors
+func (h *HostingHandler) SetErrorSolver(solver ErrorSolver) {
+	h.errorSolver = solver
+}
+
+// IngestErrorReport receives error reports from hosted apps. It speaks the
+// Sentry store and envelope protocols, so any Sentry SDK configured with
+// the deployment's DSN can report to it.
+// POST /api/v1/errors/api/:projectId/store/
+// POST /api/v1/errors/api/:projectId/envelope/
+func (h *HostingHandler) IngestErrorReport(c *gin.Context) {
+	// Reports come from the hosted app's own origin
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Credentials", "false")
+
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxErrorReportBytes)
+	if strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gzip body"})
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	payload, err := io.ReadAll(io.LimitReader(body, maxErrorReportBytes+1))
+	if err != nil || len(payload) > maxErrorReportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Error report too large"})
+		return
+	}
+
+	key := sentryKeyFromRequest(c)
+	var reports []hosting.ErrorReport
+	if strings.HasSuffix(c.FullPath(), "/envelope/") {
+		var dsn string
+		reports, dsn, err = hosting.ParseSentryEnvelope(payload)
+		if key == "" && dsn != "" {
+			if parsed, parseErr := url.Parse(dsn); parseErr == nil && parsed.User != nil {
+				key = parsed.User.Username()
+			}
+		}
+	} else {
+		var report hosting.ErrorReport
+		report, err = hosting.ParseSentryEvent(payload)
+		reports = []hosting.ErrorReport{report}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted, err := h.service.IngestErrorReports(uint(projectID), key, reports)
+	switch {
+	case errors.Is(err, hosting.ErrInvalidErrorKey):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, hosting.ErrErrorRateLimited):
+		// Sentry SDKs back off on X-Sentry-Rate-Limits
+		c.Header("Retry-After", "60")
+		c.Header("X-Sentry-Rate-Limits", "60::key")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "accepted": accepted})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store error report"})
+		return
+	}
+
+	response := gin.H{"accepted": accepted}
+	if len(reports) > 0 {
+		response["id"] = reports[0].EventID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// sentryKeyFromRequest reads the DSN public key from the X-Sentry-Auth
+// header or the sentry_key query parameter used by browser SDKs
+func sentryKeyFromRequest(c *gin.Context) string {
+	if key := c.Query("sentry_key"); key != "" {
+		return key
+	}
+	auth := strings.TrimSpace(c.GetHeader("X-Sentry-Auth"))
+	auth = strings.TrimSpace(strings.TrimPrefix(auth, "Sentry"))
+	for _, part := range strings.Split(auth, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && name == "sentry_key" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseErrorGroupID(c *gin.Context) (uint, bool) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid error group ID"})
+		return 0, false
+	}
+	return uint(groupID), true
+}
+
+func respondErrorGroupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, hosting.ErrErrorGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, hosting.ErrInvalidErrorReport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetErrorGroups returns a project's recent production errors and the DSN
+// its latest deployment reports to
+// GET /api/v1/hosting/:projectId/errors
+func (h *HostingHandler) GetErrorGroups(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	groups, err := h.service.ListErrorGroups(projectID, c.Query("deployment_id"), hosting.ErrorGroupStatus(c.Query("status")), limit)
+	if err != nil {
+		respondErrorGroupError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"success": true,
+		"groups":  groups,
+	}
+	if deployments, _, err := h.service.GetProjectDeployments(projectID, 1, 1); err == nil && len(deployments) > 0 {
+		if dsn, err := h.service.ErrorDSN(&deployments[0], publicAPIBaseURL(c)); err == nil {
+			response["dsn"] = dsn
+			response["deployment_id"] = deployments[0].ID
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetErrorGroup returns an error group with its recent events
+// GET /api/v1/hosting/:projectId/errors/:groupId
+func (h *HostingHandler) GetErrorGroup(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	groupID, ok := parseErrorGroupID(c)
+	if !ok {
+		return
+	}
+
+	group, events, err := h.service.GetErrorGroup(projectID, groupID)
+	if err != nil {
+		respondErrorGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"group":   group,
+		"events":  events,
+	})
+}
+
+// UpdateErrorGroup resolves, ignores or reopens an error group
+// PATCH /api/v1/hosting/:projectId/errors/:groupId
+func (h *HostingHandler) UpdateErrorGroup(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	groupID, ok := parseErrorGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.service.UpdateErrorGroupStatus(projectID, groupID, hosting.ErrorGroupStatus(req.Status))
+	if err != nil {
+		respondErrorGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"group":   group,
+	})
+}
+
+// SolveErrorGroup asks the Solver agent to diagnose a production error
+// from its latest report and the project files in its stack. Nothing is
+// written; the analysis can be handed to a build or applied by hand. The
+// call is charged to the caller like any other AI request.
+// POST /api/v1/hosting/:projectId/errors/:groupId/solve
+func (h *HostingHandler) SolveErrorGroup(c *gin.Context) {
+	userID, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	groupID, ok := parseErrorGroupID(c)
+	if !ok {
+		return
+	}
+	if h.errorSolver == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI error analysis is not available"})
+		return
+	}
+
+	group, events, err := h.service.GetErrorGroup(projectID, groupID)
+	if err != nil {
+		respondErrorGroupError(c, err)
+		return
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No reports are stored for this error"})
+		return
+	}
+
+	var files []models.File
+	h.db.Select("path", "content").Where("project_id = ? AND type = ?", projectID, "file").Find(&files)
+	sources := errorSourceFiles(events[0].Frames, files)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	resp, err := h.errorSolver.Generate(ctx, &ai.AIRequest{
+		ID:          uuid.New().String(),
+		Capability:  ai.CapabilityDebugging,
+		Prompt:      errorSolverPrompt(group, &events[0], sources),
+		Temperature: 0.2,
+		UserID:      strconv.Itoa(int(userID)),
+		ProjectID:   strconv.Itoa(int(projectID)),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		respondAIFailure(c, err, "AI analysis failed. Please try again.")
+		return
+	}
+
+	paths := make([]string, 0, len(sources))
+	for _, file := range sources {
+		paths = append(paths, file.Path)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"analysis": resp.Content,
+		"files":    paths,
+		"provider": resp.Provider,
+	})
+}
+
+// errorSourceFiles returns the project files named by in-app frames,
+// innermost first
+func errorSourceFiles(frames []hosting.ErrorFrame, files []models.File) []models.File {
+	var matched []models.File
+	seen := map[string]bool{}
+	for i := len(frames) - 1; i >= 0 && len(matched) < maxSolverSourceFiles; i-- {
+		if !frames[i].InApp {
+			continue
+		}
+		name := errorFramePath(frames[i].Filename)
+		if name == "" {
+			continue
+		}
+		for _, file := range files {
+			path := strings.TrimPrefix(file.Path, "/")
+			if seen[path] || (path != name && !strings.HasSuffix(name, "/"+path) && !strings.HasSuffix(path, "/"+name)) {
+				continue
+			}
+			seen[path] = true
+			matched = append(matched, file)
+			break
+		}
+	}
+	return matched
+}
+
+// errorFramePath strips URL origins, bundler schemes and the container
+// work directory from a frame filename
+func errorFramePath(filename string) string {
+	if parsed, err := url.Parse(filename); err == nil && parsed.Scheme != "" {
+		filename = parsed.Host + parsed.Path
+		if parsed.Scheme == "http" || parsed.Scheme == "https" {
+			filename = parsed.Path
+		}
+	}
+	filename = strings.TrimPrefix(filename, "/app/")
+	filename = strings.TrimPrefix(filename, "./")
+	return strings.TrimLeft(filename, "/")
+}
+
+func errorSolverPrompt(group *hosting.ErrorGroup, event *hosting.ErrorEvent, sources []models.File) string {
+	var b strings.Builder
+	b.WriteString("You are the APEX Solver agent diagnosing an error reported by a deployed app in production.\n\n")
+	fmt.Fprintf(&b, "Error: %s: %s\n", group.Type, event.Message)
+	fmt.Fprintf(&b, "Seen %d times between %s and %s", group.EventCount,
+		group.FirstSeen.UTC().Format(time.RFC3339), group.LastSeen.UTC().Format(time.RFC3339))
+	if event.URL != "" {
+		fmt.Fprintf(&b, ", latest at %s", event.URL)
+	}
+	b.WriteString(".\n\nStack trace (innermost call last):\n")
+	for _, frame := range event.Frames {
+		marker := "  "
+		if frame.InApp {
+			marker = "> "
+		}
+		fmt.Fprintf(&b, "%s%s (%s:%d:%d)\n", marker, frame.Function, frame.Filename, frame.Line, frame.Column)
+	}
+	for _, file := range sources {
+		content := file.Content
+		if len(content) > maxSolverSourceBytes {
+			content = content[:maxSolverSourceBytes] + "\n... (truncated)"
+		}
+		fmt.Fprintf(&b, "\nFile %s:\n```\n%s\n```\n", file.Path, content)
+	}
+	b.WriteString("\nExplain the root cause, then give the smallest code change that fixes it, ")
+	b.WriteString("as complete replacement snippets with their file paths. ")
+	b.WriteString("If the stack does not point at the cause, say what to log to find it.")
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentryKeyFromRequest(t *testing.T) {
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest(http.MethodPost, "/api/v1/errors/api/9/store/", nil)
+	context.Request.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=sentry.python/2.0, sentry_key=abc123")
+	require.Equal(t, "abc123", sentryKeyFromRequest(context))
+
+	context, _ = gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest(http.MethodPost, "/api/v1/errors/api/9/envelope/?sentry_key=def456&sentry_version=7", nil)
+	require.Equal(t, "def456", sentryKeyFromRequest(context))
+}
+
+func TestErrorSourceFilesMatchesInAppFrames(t *testing.T) {
+	files := []models.File{
+		{Path: "src/orders.js", Content: "export function createOrder() {}"},
+		{Path: "src/components/Cart.tsx", Content: "export function Cart() {}"},
+		{Path: "server/index.js", Content: "app.listen()"},
+	}
+	frames := []hosting.ErrorFrame{
+		{Filename: "/app/server/index.js", Function: "main", InApp: true},
+		{Filename: "node_modules/express/lib/router.js", Function: "handle", InApp: false},
+		{Filename: "https://shop.apex.app/assets/src/components/Cart.tsx", Function: "Cart", InApp: true},
+		{Filename: "webpack:///./src/orders.js", Function: "createOrder", InApp: true},
+	}
+
+	matched := errorSourceFiles(frames, files)
+	require.Len(t, matched, 3)
+	require.Equal(t, "src/orders.js", matched[0].Path, "innermost frame first")
+	require.Equal(t, "src/components/Cart.tsx", matched[1].Path)
+	require.Equal(t, "server/index.js", matched[2].Path)
+}
@@ -0,0 +1,183 @@
+// Package handlers - Log drain HTTP handlers for native hosting and preview
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/logdrain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetLogDrains enables log drain configuration
+func (h *HostingHandler) SetLogDrains(drains *logdrain.Manager) {
+	h.drains = drains
+}
+
+// authorizeLogDrainProject checks ownership and that log drains are on
+func (h *HostingHandler) authorizeLogDrainProject(c *gin.Context) (uint, uint, bool) {
+	if h.drains == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Log drains are not available"})
+		return 0, 0, false
+	}
+	return h.authorizeWorkerProject(c)
+}
+
+func parseLogDrainID(c *gin.Context) (uint, bool) {
+	drainID, err := strconv.ParseUint(c.Param("drainId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log drain ID"})
+		return 0, false
+	}
+	return uint(drainID), true
+}
+
+func respondLogDrainError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, logdrain.ErrDrainNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, logdrain.ErrInvalidDrain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetLogDrains returns a project's log drains with their delivery status
+// GET /api/v1/hosting/:projectId/log-drains
+func (h *HostingHandler) GetLogDrains(c *gin.Context) {
+	_, projectID, ok := h.authorizeLogDrainProject(c)
+	if !ok {
+		return
+	}
+
+	drains, err := h.drains.List(projectID)
+	if err != nil {
+		respondLogDrainError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"drains":  drains,
+	})
+}
+
+// CreateLogDrain adds a log drain to a project
+// POST /api/v1/hosting/:projectId/log-drains
+func (h *HostingHandler) CreateLogDrain(c *gin.Context) {
+	userID, projectID, ok := h.authorizeLogDrainProject(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name         string `json:"name" binding:"required"`
+		Type         string `json:"type" binding:"required"`
+		URL          string `json:"url"`
+		Token        string `json:"token"`
+		Sources      string `json:"sources"`
+		DeploymentID string `json:"deployment_id"`
+		Enabled      *bool  `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	drain := &logdrain.Drain{
+		ProjectID:    projectID,
+		UserID:       userID,
+		Name:         req.Name,
+		Type:         logdrain.Type(req.Type),
+		URL:          req.URL,
+		Token:        req.Token,
+		Sources:      req.Sources,
+		DeploymentID: req.DeploymentID,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if err := h.drains.Create(drain); err != nil {
+		respondLogDrainError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"drain":   drain,
+	})
+}
+
+// UpdateLogDrain changes a log drain's destination, filters or state
+// PATCH /api/v1/hosting/:projectId/log-drains/:drainId
+func (h *HostingHandler) UpdateLogDrain(c *gin.Context) {
+	_, projectID, ok := h.authorizeLogDrainProject(c)
+	if !ok {
+		return
+	}
+	drainID, ok := parseLogDrainID(c)
+	if !ok {
+		return
+	}
+
+	var req logdrain.DrainUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	drain, err := h.drains.Update(projectID, drainID, req)
+	if err != nil {
+		respondLogDrainError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"drain":   drain,
+	})
+}
+
+// DeleteLogDrain removes a log drain
+// DELETE /api/v1/hosting/:projectId/log-drains/:drainId
+func (h *HostingHandler) DeleteLogDrain(c *gin.Context) {
+	_, projectID, ok := h.authorizeLogDrainProject(c)
+	if !ok {
+		return
+	}
+	drainID, ok := parseLogDrainID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.drains.Delete(projectID, drainID); err != nil {
+		respondLogDrainError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// TestLogDrain sends a test record to a log drain right away
+// POST /api/v1/hosting/:projectId/log-drains/:drainId/test
+func (h *HostingHandler) TestLogDrain(c *gin.Context) {
+	_, projectID, ok := h.authorizeLogDrainProject(c)
+	if !ok {
+		return
+	}
+	drainID, ok := parseLogDrainID(c)
+	if !ok {
+		return
+	}
+
+	drain, err := h.drains.Test(c.Request.Context(), projectID, drainID)
+	if drain == nil {
+		respondLogDrainError(c, err)
+		return
+	}
+	response := gin.H{
+		"success":   err == nil,
+		"delivered": err == nil,
+		"drain":     drain,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,80 @@
+// Package handlers - Health check and restart history handlers for native hosting
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/hosting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateHealthCheck sets the endpoint that restarts and the always-on
+// monitor probe before a container receives traffic
+// PUT /api/v1/projects/:id/deployments/:deploymentId/health-check
+func (h *HostingHandler) UpdateHealthCheck(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+
+	var req hosting.HealthCheckConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	updated, err := h.service.UpdateHealthCheck(deployment.ID, req)
+	if err != nil {
+		if errors.Is(err, hosting.ErrInvalidHealthCheck) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update health check"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"health_check": hosting.HealthCheckConfig{
+			Path:            updated.HealthCheckPath,
+			TimeoutSeconds:  updated.HealthCheckTimeout,
+			IntervalSeconds: updated.HealthCheckInterval,
+		},
+	})
+}
+
+// GetDeploymentEvents returns a deployment's lifecycle events, such as
+// restarts with their reasons, and the restart controller's state
+// GET /api/v1/projects/:id/deployments/:deploymentId/events?type=restart&limit=50
+func (h *HostingHandler) GetDeploymentEvents(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	events, err := h.service.GetDeploymentEvents(deployment.ID, c.Query("type"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load deployment events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"events":  events,
+		"restarts": gin.H{
+			"restart_count":          deployment.RestartCount,
+			"max_restarts":           deployment.MaxRestarts,
+			"next_restart_at":        deployment.NextRestartAt,
+			"crash_loop_detected_at": deployment.CrashLoopDetectedAt,
+		},
+		"health_check": hosting.HealthCheckConfig{
+			Path:            deployment.HealthCheckPath,
+			TimeoutSeconds:  deployment.HealthCheckTimeout,
+			IntervalSeconds: deployment.HealthCheckInterval,
+		},
+	})
+}
@@ -0,0 +1,157 @@
+// Package handlers - Security header HTTP handlers for native hosting
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCSPReportBytes caps a CSP violation report request body
+const maxCSPReportBytes = 64 << 10
+
+// authorizeDeployment checks project ownership and that the deployment
+// belongs to the project
+func (h *HostingHandler) authorizeDeployment(c *gin.Context) (*hosting.NativeDeployment, bool) {
+	userID := c.GetUint("user_id")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return nil, false
+	}
+
+	var project models.Project
+	if err := h.db.First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	if project.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	deployment, err := h.service.GetDeployment(c.Param("deploymentId"))
+	if err != nil || deployment.ProjectID != uint(projectID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return nil, false
+	}
+	return deployment, true
+}
+
+// securityHeadersResponse describes a deployment's configured headers next
+// to the headers the proxy will actually send
+func securityHeadersResponse(deployment *hosting.NativeDeployment) gin.H {
+	return gin.H{
+		"success":       true,
+		"deployment_id": deployment.ID,
+		"framework":     deployment.Framework,
+		"configured":    deployment.SecurityHeaders,
+		"effective":     deployment.SecurityHeaders.Resolved(deployment.Framework),
+		"defaults":      hosting.DefaultSecurityHeaders(deployment.Framework),
+	}
+}
+
+// GetSecurityHeaders returns a deployment's security header configuration
+// GET /api/v1/projects/:id/deployments/:deploymentId/security-headers
+func (h *HostingHandler) GetSecurityHeaders(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, securityHeadersResponse(deployment))
+}
+
+// UpdateSecurityHeaders sets a deployment's security headers. Later
+// deployments of the project keep them. An empty body restores the
+// generated defaults.
+// PUT /api/v1/projects/:id/deployments/:deploymentId/security-headers
+func (h *HostingHandler) UpdateSecurityHeaders(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+
+	var req hosting.SecurityHeaders
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	headers := &req
+	if headers.IsZero() {
+		headers = nil
+	}
+
+	if err := h.service.UpdateSecurityHeaders(deployment.ID, headers); err != nil {
+		if errors.Is(err, hosting.ErrInvalidSecurityHeaders) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security headers"})
+		return
+	}
+	deployment.SecurityHeaders = headers
+	c.JSON(http.StatusOK, securityHeadersResponse(deployment))
+}
+
+// GetCSPViolations returns the CSP violations browsers reported for a
+// deployment, most recently seen first
+// GET /api/v1/projects/:id/deployments/:deploymentId/csp-reports
+func (h *HostingHandler) GetCSPViolations(c *gin.Context) {
+	deployment, ok := h.authorizeDeployment(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	violations, err := h.service.ListCSPViolations(deployment.ProjectID, deployment.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list CSP violations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"violations": violations,
+	})
+}
+
+// IngestCSPReport receives CSP violation reports from visitors' browsers,
+// as application/csp-report (report-uri) or application/reports+json
+// (report-to)
+// POST /api/v1/csp-reports/:deploymentId
+func (h *HostingHandler) IngestCSPReport(c *gin.Context) {
+	// Reports come from the hosted app's own origin
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Credentials", "false")
+
+	payload, err := io.ReadAll(io.LimitReader(http.MaxBytesReader(c.Writer, c.Request.Body, maxCSPReportBytes), maxCSPReportBytes+1))
+	if err != nil || len(payload) > maxCSPReportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "CSP report too large"})
+		return
+	}
+	reports, err := hosting.ParseCSPReports(payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted, err := h.service.IngestCSPReports(c.Param("deploymentId"), reports)
+	switch {
+	case errors.Is(err, hosting.ErrCSPDeploymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, hosting.ErrCSPRateLimited):
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "accepted": accepted})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store CSP report"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,329 @@
+// Package handlers - Background worker HTTP handlers for native hosting
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/database"
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// workersMustScaleToZero reports whether a plan only gets scale-to-zero
+// workers. Lower tiers do not get always-running background processes.
+func workersMustScaleToZero(planType string) bool {
+	switch planType {
+	case "free", "builder":
+		return true
+	}
+	return false
+}
+
+// authorizeWorkerProject parses :projectId and checks ownership
+func (h *HostingHandler) authorizeWorkerProject(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, 0, false
+	}
+
+	var project models.Project
+	if err := h.db.First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return 0, 0, false
+	}
+	if project.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return 0, 0, false
+	}
+	return userID, uint(projectID), true
+}
+
+func parseWorkerID(c *gin.Context) (uint, bool) {
+	workerID, err := strconv.ParseUint(c.Param("workerId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worker ID"})
+		return 0, false
+	}
+	return uint(workerID), true
+}
+
+func respondWorkerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, hosting.ErrWorkerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, hosting.ErrInvalidWorker):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, hosting.ErrQueueUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetWorkers returns a project's background worker processes
+// GET /api/v1/hosting/:projectId/workers
+func (h *HostingHandler) GetWorkers(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+
+	workers, err := h.service.ListWorkers(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"workers": workers,
+	})
+}
+
+// CreateWorker adds a background worker process to a project
+// POST /api/v1/hosting/:projectId/workers
+func (h *HostingHandler) CreateWorker(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if !requirePaidBackendPlan(c, h.db, userID, "background workers") {
+		return
+	}
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+
+	var config hosting.WorkerConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if workersMustScaleToZero(currentSubscriptionType(c, h.db, userID)) {
+		config.ScaleToZero = true
+	}
+
+	worker, err := h.service.CreateWorker(c.Request.Context(), projectID, userID, &config)
+	if err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"worker":  worker,
+	})
+}
+
+// UpdateWorker changes a worker's command, replicas, limits, or idle policy
+// PATCH /api/v1/hosting/:projectId/workers/:workerId
+func (h *HostingHandler) UpdateWorker(c *gin.Context) {
+	userID, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	workerID, ok := parseWorkerID(c)
+	if !ok {
+		return
+	}
+
+	var update hosting.WorkerUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.ScaleToZero != nil && !*update.ScaleToZero && workersMustScaleToZero(currentSubscriptionType(c, h.db, userID)) {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":         "Always-running workers require a Pro plan or higher",
+			"required_plan": "pro",
+		})
+		return
+	}
+
+	worker, err := h.service.UpdateWorker(c.Request.Context(), projectID, workerID, &update)
+	if err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"worker":  worker,
+	})
+}
+
+// RestartWorker restarts (or wakes) a worker
+// POST /api/v1/hosting/:projectId/workers/:workerId/restart
+func (h *HostingHandler) RestartWorker(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	workerID, ok := parseWorkerID(c)
+	if !ok {
+		return
+	}
+
+	worker, err := h.service.RestartWorker(c.Request.Context(), projectID, workerID)
+	if err != nil {
+		if errors.Is(err, hosting.ErrWorkerNotFound) {
+			respondWorkerError(c, err)
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"worker":  worker,
+	})
+}
+
+// DeleteWorker stops and removes a worker
+// DELETE /api/v1/hosting/:projectId/workers/:workerId
+func (h *HostingHandler) DeleteWorker(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	workerID, ok := parseWorkerID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWorker(c.Request.Context(), projectID, workerID); err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Worker removed",
+	})
+}
+
+// GetWorkerLogs returns a worker's logs
+// GET /api/v1/hosting/:projectId/workers/:workerId/logs
+func (h *HostingHandler) GetWorkerLogs(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	workerID, ok := parseWorkerID(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	logs, err := h.service.GetWorkerLogs(projectID, workerID, limit)
+	if err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"logs":    logs,
+	})
+}
+
+// GetWorkerMetrics returns a worker's runtime metrics
+// GET /api/v1/hosting/:projectId/workers/:workerId/metrics
+func (h *HostingHandler) GetWorkerMetrics(c *gin.Context) {
+	_, projectID, ok := h.authorizeWorkerProject(c)
+	if !ok {
+		return
+	}
+	workerID, ok := parseWorkerID(c)
+	if !ok {
+		return
+	}
+
+	metrics, err := h.service.GetWorkerMetrics(projectID, workerID)
+	if err != nil {
+		respondWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"metrics": metrics,
+	})
+}
+
+// ManagedQueueProvisioner backs worker queues with a managed Redis namespace
+type ManagedQueueProvisioner struct {
+	db      *gorm.DB
+	manager *database.DatabaseManager
+}
+
+// NewManagedQueueProvisioner creates a queue provisioner over managed databases
+func NewManagedQueueProvisioner(db *gorm.DB, manager *database.DatabaseManager) *ManagedQueueProvisioner {
+	return &ManagedQueueProvisioner{db: db, manager: manager}
+}
+
+const workerQueueName = "queue"
+
+// ProvisionQueue returns the project's Redis queue, creating it if needed
+func (p *ManagedQueueProvisioner) ProvisionQueue(ctx context.Context, projectID, userID uint) (uint, error) {
+	var existing database.ManagedDatabase
+	err := p.db.WithContext(ctx).
+		Where("project_id = ? AND type = ? AND name = ?", projectID, database.DatabaseTypeRedis, workerQueueName).
+		First(&existing).Error
+	if err == nil {
+		return existing.ID, nil
+	}
+
+	queue := &database.ManagedDatabase{
+		ProjectID:      projectID,
+		UserID:         userID,
+		Name:           workerQueueName,
+		Type:           database.DatabaseTypeRedis,
+		Status:         database.DatabaseStatusProvisioning,
+		BackupEnabled:  false,
+		MaxStorageMB:   100,
+		MaxConnections: 20,
+	}
+	if err := p.manager.CreateDatabase(queue); err != nil {
+		return 0, err
+	}
+	if err := p.db.WithContext(ctx).Create(queue).Error; err != nil {
+		return 0, fmt.Errorf("failed to save queue record: %w", err)
+	}
+	return queue.ID, nil
+}
+
+// QueueEnv returns the variables that connect a process to the queue. The
+// key prefix isolates the project inside the shared Redis.
+func (p *ManagedQueueProvisioner) QueueEnv(ctx context.Context, databaseID uint) (map[string]string, error) {
+	var queue database.ManagedDatabase
+	if err := p.db.WithContext(ctx).First(&queue, databaseID).Error; err != nil {
+		return nil, fmt.Errorf("queue not found")
+	}
+	url := fmt.Sprintf("redis://%s:%d/0", queue.Host, queue.Port)
+	return map[string]string{
+		"REDIS_URL":        url,
+		"QUEUE_URL":        url,
+		"QUEUE_KEY_PREFIX": queue.DatabaseName + ":",
+	}, nil
+}
+
+// ReleaseQueue deletes the queue's keys and record
+func (p *ManagedQueueProvisioner) ReleaseQueue(ctx context.Context, databaseID uint) error {
+	var queue database.ManagedDatabase
+	if err := p.db.WithContext(ctx).First(&queue, databaseID).Error; err != nil {
+		return nil
+	}
+	if err := p.manager.DeleteDatabase(&queue); err != nil {
+		return err
+	}
+	return p.db.WithContext(ctx).Delete(&queue).Error
+}
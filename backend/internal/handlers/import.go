@@ -1,4 +1,5 @@
 // Package handlers - GitHub Repository Import Handler for APEX.BUILD
+// (GitLab, Bitbucket and archive imports live in import_sources.go)
 // Enables one-click import of GitHub repositories similar to Replit's replit.new/URL feature
 package handlers
 
@@ -26,6 +27,7 @@ type ImportHandler struct {
 	db             *gorm.DB
 	gitService     *git.GitService
 	secretsManager *secrets.SecretsManager
+	httpClient     *http.Client // archive downloads; nil uses importHTTPClient
 }
 
 // NewImportHandler creates a new import handler
@@ -54,6 +56,8 @@ type GitHubImportResponse struct {
 	Framework      string                 `json:"framework"`
 	DetectedStack  map[string]interface{} `json:"detected_stack"`
 	FileCount      int                    `json:"file_count"`
+	SkippedFiles   int                    `json:"skipped_files,omitempty"` // binary or oversized files left out
+	Source         string                 `json:"source,omitempty"`        // github, gitlab, bitbucket, archive, upload
 	Status         string                 `json:"status"`
 	Message        string                 `json:"message"`
 	ImportDuration int64                  `json:"import_duration_ms"`
@@ -200,6 +204,7 @@ func (h *ImportHandler) ImportGitHub(c *gin.Context) {
 
 	// Step 6: Download and store files
 	fileCount := 0
+	lfsRemote := git.GitHubLFSRemote(owner, repo, req.Token)
 	for _, file := range files {
 		if file.Type == "blob" && !shouldSkipFile(file.Path) {
 			content, err := h.getGitHubFileContent(ctx, owner, repo, file.Path, repoInfo.DefaultBranch, req.Token)
@@ -220,11 +225,10 @@ func (h *ImportHandler) ImportGitHub(c *gin.Context) {
 				Path:      file.Path,
 				Name:      fileName,
 				Type:      fileType,
-				Content:   content,
-				Size:      int64(len(content)),
 				MimeType:  mimeType,
 				Version:   1,
 			}
+			h.setImportedContent(ctx, &lfsRemote, dbFile, content)
 
 			if err := h.db.Create(dbFile).Error; err == nil {
 				fileCount++
@@ -327,6 +331,9 @@ func (h *ImportHandler) RegisterImportRoutes(rg *gin.RouterGroup) {
 	{
 		imports.POST("/github", h.ImportGitHub)
 		imports.POST("/github/validate", h.ValidateGitHubURL)
+		imports.POST("/repository", h.ImportRepository)
+		imports.POST("/archive", h.ImportArchiveURL)
+		imports.POST("/archive/upload", h.ImportArchiveUpload)
 	}
 }
 
@@ -671,6 +678,36 @@ func (h *ImportHandler) detectLanguageAndFramework(files []GitHubTreeEntry) Lang
 			detection.EntryPoint = "public/index.php"
 		}
 
+	case hasFile["build.gradle.kts"] && extCount[".kt"] > 0:
+		detection.PrimaryLanguage = "kotlin"
+		detection.PackageManager = "gradle"
+		detection.EntryPoint = "src/main/kotlin/Main.kt"
+
+		if hasFile["ktor"] {
+			detection.Framework = "ktor"
+		}
+
+	case hasFile["package.swift"]:
+		detection.PrimaryLanguage = "swift"
+		detection.PackageManager = "swiftpm"
+
+		if hasFile["vapor"] {
+			detection.Framework = "vapor"
+		}
+
+	case extCount[".csproj"] > 0 || extCount[".sln"] > 0:
+		detection.PrimaryLanguage = "csharp"
+		detection.PackageManager = "nuget"
+		detection.EntryPoint = "Program.cs"
+
+	case hasFile["mix.exs"]:
+		detection.PrimaryLanguage = "elixir"
+		detection.PackageManager = "mix"
+
+		if hasFile["phoenix"] || extCount[".heex"] > 0 {
+			detection.Framework = "phoenix"
+		}
+
 	case hasFile["pom.xml"] || hasFile["build.gradle"]:
 		detection.PrimaryLanguage = "java"
 		if hasFile["build.gradle"] {
@@ -714,6 +751,8 @@ func (h *ImportHandler) detectLanguageAndFramework(files []GitHubTreeEntry) Lang
 					detection.PrimaryLanguage = "swift"
 				case ".kt":
 					detection.PrimaryLanguage = "kotlin"
+				case ".ex", ".exs":
+					detection.PrimaryLanguage = "elixir"
 				}
 			}
 		}
@@ -0,0 +1,801 @@
+// Package handlers - Archive-based project import for APEX.BUILD
+// Imports GitLab, Bitbucket and GitHub repositories, arbitrary .zip/.tar.gz
+// URLs and uploaded archives through one path: fetch an archive, unpack it,
+// detect the stack and create the project.
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"apex-build/internal/git"
+	appmiddleware "apex-build/internal/middleware"
+	"apex-build/internal/netguard"
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportArchiveBytes = 100 * 1024 * 1024 // compressed archive size
+	maxImportTotalBytes   = 200 * 1024 * 1024 // uncompressed bytes read from an archive
+	maxImportFileBytes    = 2 * 1024 * 1024   // larger files are skipped
+	maxImportFiles        = 5000
+)
+
+var (
+	errImportArchiveTooLarge = errors.New("archive exceeds the 100 MB import limit")
+	repositoryNamePattern    = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+)
+
+// RepositoryImportRequest imports a GitHub, GitLab or Bitbucket repository.
+type RepositoryImportRequest struct {
+	URL         string `json:"url" binding:"required"`
+	Provider    string `json:"provider"` // optional: github, gitlab, bitbucket (required for self-hosted GitLab)
+	Branch      string `json:"branch"`   // defaults to the repository's default branch
+	ProjectName string `json:"project_name"`
+	Description string `json:"description"`
+	IsPublic    bool   `json:"is_public"`
+	Token       string `json:"token"`       // optional access token
+	SecretName  string `json:"secret_name"` // optional: name of a stored secret holding the token
+}
+
+// ArchiveImportRequest imports a .zip or .tar.gz archive from a URL.
+type ArchiveImportRequest struct {
+	URL         string `json:"url" binding:"required"`
+	ProjectName string `json:"project_name"`
+	Description string `json:"description"`
+	IsPublic    bool   `json:"is_public"`
+}
+
+// repositoryRef identifies a hosted repository parsed from a URL.
+type repositoryRef struct {
+	Provider string // github, gitlab, bitbucket
+	Host     string
+	Owner    string // namespace; may contain "/" for GitLab subgroups
+	Repo     string
+}
+
+// importSource describes where an archive came from, for the project record.
+type importSource struct {
+	Kind          string // github, gitlab, bitbucket, archive, upload
+	URL           string
+	DefaultBranch string
+	ProjectName   string
+	Description   string
+	IsPublic      bool
+	LFS           *git.LFSRemote // resolves Git LFS pointers; nil for plain archives
+}
+
+// archiveFile is a text file unpacked from an import archive.
+type archiveFile struct {
+	Path    string
+	Content string
+}
+
+// ImportRepository handles importing a GitHub, GitLab or Bitbucket repository
+// as an archive. Tokens come from the request or from the user's secrets
+// (GITHUB_TOKEN, GITLAB_TOKEN, BITBUCKET_TOKEN by default).
+// POST /api/v1/projects/import/repository
+func (h *ImportHandler) ImportRepository(c *gin.Context) {
+	startTime := time.Now()
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req RepositoryImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.IsPublic && !requirePaidBackendPlan(c, h.db, uid, "Publishing projects") {
+		return
+	}
+
+	ref, err := parseRepositoryURL(req.URL, req.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.importCredential(uid, ref.Provider, req.Token, req.SecretName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	name, description, defaultBranch, err := h.repositoryMetadata(ctx, ref, token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to access repository: %v", err),
+			"hint":  "Make sure the repository exists and you have access to it. For private repos, provide a token or store one as a secret.",
+		})
+		return
+	}
+	branch := req.Branch
+	if branch == "" {
+		branch = defaultBranch
+	}
+
+	body, err := h.fetchArchive(ctx, repositoryArchiveURL(ref, branch), repositoryAuthHeaders(ref.Provider, token))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to download repository archive: %v", err)})
+		return
+	}
+	defer body.Close()
+
+	src := importSource{
+		Kind:          ref.Provider,
+		URL:           req.URL,
+		DefaultBranch: branch,
+		ProjectName:   req.ProjectName,
+		Description:   req.Description,
+		IsPublic:      req.IsPublic,
+		LFS:           repositoryLFSRemote(ref, token),
+	}
+	if src.ProjectName == "" {
+		src.ProjectName = name
+	}
+	if src.Description == "" {
+		src.Description = description
+	}
+	h.importArchive(c, uid, src, body, startTime)
+}
+
+// ImportArchiveURL handles importing a .zip or .tar.gz archive from a URL
+// POST /api/v1/projects/import/archive
+func (h *ImportHandler) ImportArchiveURL(c *gin.Context) {
+	startTime := time.Now()
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ArchiveImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.IsPublic && !requirePaidBackendPlan(c, h.db, uid, "Publishing projects") {
+		return
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Archive URL must be an http(s) URL to a .zip or .tar.gz file"})
+		return
+	}
+
+	body, err := h.fetchArchive(c.Request.Context(), parsed.String(), nil)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to download archive: %v", err)})
+		return
+	}
+	defer body.Close()
+
+	src := importSource{
+		Kind:        "archive",
+		URL:         parsed.String(),
+		ProjectName: req.ProjectName,
+		Description: req.Description,
+		IsPublic:    req.IsPublic,
+	}
+	if src.ProjectName == "" {
+		src.ProjectName = archiveBaseName(parsed.Path)
+	}
+	h.importArchive(c, uid, src, body, startTime)
+}
+
+// ImportArchiveUpload handles importing an uploaded .zip or .tar.gz archive
+// (multipart field "file", optional project_name, description, is_public)
+// POST /api/v1/projects/import/archive/upload
+func (h *ImportHandler) ImportArchiveUpload(c *gin.Context) {
+	startTime := time.Now()
+	uid, ok := appmiddleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportArchiveBytes+1024*1024)
+	upload, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No archive provided — use field name 'file' (max 100 MB)"})
+		return
+	}
+	defer upload.Close()
+
+	src := importSource{
+		Kind:        "upload",
+		ProjectName: strings.TrimSpace(c.PostForm("project_name")),
+		Description: c.PostForm("description"),
+		IsPublic:    c.PostForm("is_public") == "true",
+	}
+	if src.IsPublic && !requirePaidBackendPlan(c, h.db, uid, "Publishing projects") {
+		return
+	}
+	if src.ProjectName == "" {
+		src.ProjectName = archiveBaseName(header.Filename)
+	}
+	h.importArchive(c, uid, src, upload, startTime)
+}
+
+// importArchive unpacks an archive into a new project, with the same stack
+// detection and environment setup as the GitHub import.
+func (h *ImportHandler) importArchive(c *gin.Context, uid uint, src importSource, archive io.Reader, startTime time.Time) {
+	files, skipped, err := extractImportArchive(archive)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errImportArchiveTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("Failed to read archive: %v", err)})
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Archive contains no importable files"})
+		return
+	}
+
+	entries := make([]GitHubTreeEntry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, GitHubTreeEntry{Path: file.Path, Type: "blob", Size: len(file.Content)})
+	}
+	detection := h.detectLanguageAndFramework(entries)
+	applyManifestDetails(&detection, files)
+
+	projectName := src.ProjectName
+	if projectName == "" {
+		projectName = "imported-project"
+	}
+	var existingProject models.Project
+	if err := h.db.Where("owner_id = ? AND name = ?", uid, projectName).First(&existingProject).Error; err == nil {
+		projectName = fmt.Sprintf("%s-%d", projectName, time.Now().Unix())
+	}
+
+	environment := map[string]interface{}{
+		"import_source":   src.Kind,
+		"package_manager": detection.PackageManager,
+	}
+	if src.URL != "" {
+		environment["import_url"] = src.URL
+	}
+	if src.DefaultBranch != "" {
+		environment["default_branch"] = src.DefaultBranch
+	}
+
+	project := &models.Project{
+		Name:        projectName,
+		Description: src.Description,
+		Language:    detection.PrimaryLanguage,
+		Framework:   detection.Framework,
+		OwnerID:     uid,
+		IsPublic:    src.IsPublic,
+		EntryPoint:  detection.EntryPoint,
+		Environment: environment,
+		Dependencies: map[string]interface{}{
+			"dependencies":    detection.Dependencies,
+			"devDependencies": detection.DevDependencies,
+		},
+		BuildConfig: map[string]interface{}{
+			"scripts": detection.Scripts,
+		},
+	}
+	if err := h.db.Create(project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
+	}
+
+	fileCount := 0
+	for _, file := range files {
+		dbFile := &models.File{
+			ProjectID: project.ID,
+			Path:      file.Path,
+			Name:      path.Base(file.Path),
+			Type:      "file",
+			MimeType:  getFileMimeType(path.Base(file.Path)),
+			Version:   1,
+		}
+		h.setImportedContent(c.Request.Context(), src.LFS, dbFile, file.Content)
+		if err := h.db.Create(dbFile).Error; err == nil {
+			fileCount++
+		}
+	}
+
+	source := src.URL
+	if source == "" {
+		source = "the uploaded archive"
+	}
+	c.JSON(http.StatusCreated, GitHubImportResponse{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		Language:    detection.PrimaryLanguage,
+		Framework:   detection.Framework,
+		DetectedStack: map[string]interface{}{
+			"language":        detection.PrimaryLanguage,
+			"framework":       detection.Framework,
+			"package_manager": detection.PackageManager,
+			"entry_point":     detection.EntryPoint,
+		},
+		FileCount:      fileCount,
+		SkippedFiles:   skipped,
+		Source:         src.Kind,
+		Status:         "completed",
+		Message:        fmt.Sprintf("Successfully imported %d files from %s", fileCount, source),
+		ImportDuration: time.Since(startTime).Milliseconds(),
+		RepositoryURL:  src.URL,
+		DefaultBranch:  src.DefaultBranch,
+	})
+}
+
+// parseRepositoryURL recognizes GitHub, GitLab and Bitbucket URLs
+// (https, scheme-less and git@ forms, with or without .git or a trailing
+// /tree/... path). provider forces a host type, for self-hosted GitLab.
+func parseRepositoryURL(raw, provider string) (*repositoryRef, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "git@") {
+		raw = "https://" + strings.Replace(strings.TrimPrefix(raw, "git@"), ":", "/", 1)
+	} else if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid repository URL")
+	}
+
+	host := strings.ToLower(parsed.Host)
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		switch host {
+		case "github.com", "www.github.com":
+			provider = "github"
+		case "gitlab.com", "www.gitlab.com":
+			provider = "gitlab"
+		case "bitbucket.org", "www.bitbucket.org":
+			provider = "bitbucket"
+		default:
+			return nil, fmt.Errorf("unrecognized repository host %q; set provider to \"gitlab\" for self-hosted GitLab", parsed.Host)
+		}
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	// Drop browse paths: GitHub /tree/..., GitLab /-/tree/..., Bitbucket /src/...
+	for i, segment := range segments {
+		if segment == "-" || (i >= 2 && (segment == "tree" || segment == "blob" || segment == "src")) {
+			segments = segments[:i]
+			break
+		}
+	}
+	if len(segments) > 0 {
+		segments[len(segments)-1] = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	}
+
+	switch provider {
+	case "github", "bitbucket":
+		if len(segments) != 2 {
+			return nil, fmt.Errorf("invalid %s repository URL. Expected: https://%s/owner/repo", provider, host)
+		}
+	case "gitlab":
+		if len(segments) < 2 {
+			return nil, fmt.Errorf("invalid GitLab repository URL. Expected: https://%s/group/project", host)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", provider)
+	}
+	for _, segment := range segments {
+		if !repositoryNamePattern.MatchString(segment) || strings.Trim(segment, ".") == "" {
+			return nil, fmt.Errorf("invalid repository URL")
+		}
+	}
+
+	return &repositoryRef{
+		Provider: provider,
+		Host:     host,
+		Owner:    strings.Join(segments[:len(segments)-1], "/"),
+		Repo:     segments[len(segments)-1],
+	}, nil
+}
+
+// importCredential resolves the access token for a repository import: the
+// request's token, else the named secret, else the provider's default secret
+// (e.g. GITLAB_TOKEN) if the user has stored one.
+func (h *ImportHandler) importCredential(userID uint, provider, token, secretName string) (string, error) {
+	if token != "" {
+		return token, nil
+	}
+	name := secretName
+	if name == "" {
+		name = strings.ToUpper(provider) + "_TOKEN"
+	}
+	if h.secretsManager == nil {
+		if secretName != "" {
+			return "", fmt.Errorf("secrets are not available")
+		}
+		return "", nil
+	}
+
+	var secret secrets.Secret
+	if err := h.db.Where("user_id = ? AND project_id IS NULL AND name = ?", userID, name).First(&secret).Error; err != nil {
+		if secretName != "" {
+			return "", fmt.Errorf("secret %q not found", secretName)
+		}
+		return "", nil
+	}
+	value, err := h.secretsManager.Decrypt(userID, secret.EncryptedValue, secret.Salt)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %q", name)
+	}
+	return value, nil
+}
+
+// repositoryAuthHeaders builds the provider's auth header for token.
+// Bitbucket app passwords are given as "username:app_password".
+func repositoryAuthHeaders(provider, token string) map[string]string {
+	if token == "" {
+		return nil
+	}
+	switch provider {
+	case "gitlab":
+		return map[string]string{"PRIVATE-TOKEN": token}
+	case "bitbucket":
+		if user, pass, ok := strings.Cut(token, ":"); ok {
+			req := &http.Request{Header: http.Header{}}
+			req.SetBasicAuth(user, pass)
+			return map[string]string{"Authorization": req.Header.Get("Authorization")}
+		}
+	}
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// repositoryLFSRemote returns the Git LFS endpoint for a repository, using
+// the same credentials as the archive download.
+func repositoryLFSRemote(ref *repositoryRef, token string) *git.LFSRemote {
+	repoURL := fmt.Sprintf("https://%s/%s/%s", ref.Host, ref.Owner, ref.Repo)
+	var remote git.LFSRemote
+	switch ref.Provider {
+	case "github":
+		remote = git.GitHubLFSRemote(ref.Owner, ref.Repo, token)
+	case "gitlab":
+		remote = git.NewLFSRemote(repoURL, "oauth2", token)
+	default:
+		user, pass, ok := strings.Cut(token, ":")
+		if !ok {
+			user, pass = "x-token-auth", token
+		}
+		remote = git.NewLFSRemote(repoURL, user, pass)
+	}
+	return &remote
+}
+
+// setImportedContent stores imported file content, replacing Git LFS
+// pointers with their objects when the source has an LFS server.
+func (h *ImportHandler) setImportedContent(ctx context.Context, remote *git.LFSRemote, file *models.File, content string) {
+	if h.gitService == nil || remote == nil {
+		file.Content = content
+		file.Size = int64(len(content))
+		return
+	}
+	h.gitService.SetImportedContent(ctx, *remote, file, content)
+}
+
+// repositoryMetadata fetches the repository's name, description and default
+// branch from the provider's API.
+func (h *ImportHandler) repositoryMetadata(ctx context.Context, ref *repositoryRef, token string) (name, description, defaultBranch string, err error) {
+	switch ref.Provider {
+	case "github":
+		info, err := h.getGitHubRepoInfo(ctx, ref.Owner, ref.Repo, token)
+		if err != nil {
+			return "", "", "", err
+		}
+		return info.Name, info.Description, info.DefaultBranch, nil
+	case "gitlab":
+		var info struct {
+			Name          string `json:"name"`
+			Description   string `json:"description"`
+			DefaultBranch string `json:"default_branch"`
+		}
+		apiURL := fmt.Sprintf("https://%s/api/v4/projects/%s", ref.Host, url.PathEscape(ref.Owner+"/"+ref.Repo))
+		if err := h.getImportJSON(ctx, apiURL, repositoryAuthHeaders("gitlab", token), &info); err != nil {
+			return "", "", "", err
+		}
+		return info.Name, info.Description, info.DefaultBranch, nil
+	default:
+		var info struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			MainBranch  struct {
+				Name string `json:"name"`
+			} `json:"mainbranch"`
+		}
+		apiURL := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/%s", url.PathEscape(ref.Owner), url.PathEscape(ref.Repo))
+		if err := h.getImportJSON(ctx, apiURL, repositoryAuthHeaders("bitbucket", token), &info); err != nil {
+			return "", "", "", err
+		}
+		return info.Name, info.Description, info.MainBranch.Name, nil
+	}
+}
+
+// repositoryArchiveURL is the zip download URL for a branch.
+func repositoryArchiveURL(ref *repositoryRef, branch string) string {
+	switch ref.Provider {
+	case "github":
+		return fmt.Sprintf("https://api.github.com/repos/%s/%s/zipball/%s", ref.Owner, ref.Repo, url.PathEscape(branch))
+	case "gitlab":
+		return fmt.Sprintf("https://%s/api/v4/projects/%s/repository/archive.zip?sha=%s", ref.Host, url.PathEscape(ref.Owner+"/"+ref.Repo), url.QueryEscape(branch))
+	default:
+		return fmt.Sprintf("https://bitbucket.org/%s/%s/get/%s.zip", ref.Owner, ref.Repo, url.PathEscape(branch))
+	}
+}
+
+func (h *ImportHandler) getImportJSON(ctx context.Context, apiURL string, headers map[string]string, target interface{}) error {
+	body, err := h.fetchArchive(ctx, apiURL, headers)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(io.LimitReader(body, 1024*1024)).Decode(target)
+}
+
+// fetchArchive GETs url with the import client and returns the body on 200.
+func (h *ImportHandler) fetchArchive(ctx context.Context, rawURL string, headers map[string]string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "APEX.BUILD")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := h.httpClient
+	if client == nil {
+		client = importHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("not found or private")
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, fmt.Errorf("access denied")
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// importHTTPClient fetches user-supplied URLs. It refuses to connect to
+// loopback, private and link-local addresses (checked after DNS resolution
+// and on every redirect) so imports can't reach internal services.
+var importHTTPClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: netguard.DenyInternal("import URLs"),
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// extractImportArchive unpacks a .zip or .tar(.gz) archive into text files.
+// A single top-level directory (as in repository archives) is stripped.
+// Binary and oversized files are skipped and counted; paths excluded by
+// shouldSkipFile are dropped.
+func extractImportArchive(r io.Reader) ([]archiveFile, int, error) {
+	spool, err := os.CreateTemp("", "apex-import-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, io.LimitReader(r, maxImportArchiveBytes+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if size > maxImportArchiveBytes {
+		return nil, 0, errImportArchiveTooLarge
+	}
+
+	magic := make([]byte, 512)
+	n, _ := spool.ReadAt(magic, 0)
+	magic = magic[:n]
+
+	var files []archiveFile
+	skipped := 0
+	var total int64
+	add := func(name string, entrySize int64, open func() (io.Reader, error)) error {
+		if len(files)+skipped >= maxImportFiles {
+			return fmt.Errorf("archive has more than %d files", maxImportFiles)
+		}
+		name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))[1:]
+		if name == "" || name == "." {
+			return nil
+		}
+		if entrySize > maxImportFileBytes {
+			skipped++
+			return nil
+		}
+		reader, err := open()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(io.LimitReader(reader, maxImportFileBytes+1))
+		if err != nil {
+			return err
+		}
+		total += int64(len(content))
+		if total > maxImportTotalBytes {
+			return fmt.Errorf("archive expands to more than %d MB", maxImportTotalBytes/1024/1024)
+		}
+		if len(content) > maxImportFileBytes || !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+			skipped++
+			return nil
+		}
+		files = append(files, archiveFile{Path: name, Content: string(content)})
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		archive, err := zip.NewReader(spool, size)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		for _, entry := range archive.File {
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			var rc io.ReadCloser
+			err := add(entry.Name, int64(entry.UncompressedSize64), func() (io.Reader, error) {
+				var err error
+				rc, err = entry.Open()
+				return rc, err
+			})
+			if rc != nil {
+				rc.Close()
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+	default:
+		var stream io.Reader = bufio.NewReader(io.NewSectionReader(spool, 0, size))
+		if bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) {
+			gz, err := gzip.NewReader(stream)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid gzip archive: %w", err)
+			}
+			defer gz.Close()
+			stream = gz
+		} else if len(magic) < 262 || string(magic[257:262]) != "ustar" {
+			return nil, 0, fmt.Errorf("unsupported archive format; use .zip or .tar.gz")
+		}
+		archive := tar.NewReader(stream)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid tar archive: %w", err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(header.Name, header.Size, func() (io.Reader, error) { return archive, nil }); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	files = stripArchiveRoot(files)
+	kept := files[:0]
+	for _, file := range files {
+		if !shouldSkipFile(file.Path) {
+			kept = append(kept, file)
+		}
+	}
+	return kept, skipped, nil
+}
+
+// stripArchiveRoot removes a directory that wraps every file, such as the
+// "repo-<sha>/" prefix of repository archives.
+func stripArchiveRoot(files []archiveFile) []archiveFile {
+	if len(files) == 0 {
+		return files
+	}
+	root, _, ok := strings.Cut(files[0].Path, "/")
+	if !ok {
+		return files
+	}
+	prefix := root + "/"
+	for _, file := range files {
+		if !strings.HasPrefix(file.Path, prefix) {
+			return files
+		}
+	}
+	for i := range files {
+		files[i].Path = strings.TrimPrefix(files[i].Path, prefix)
+	}
+	return files
+}
+
+// applyManifestDetails fills dependencies and scripts from a root
+// package.json, which archive imports have in hand.
+func applyManifestDetails(detection *LanguageDetection, files []archiveFile) {
+	for _, file := range files {
+		if file.Path != "package.json" {
+			continue
+		}
+		var manifest struct {
+			Main            string            `json:"main"`
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+			Scripts         map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal([]byte(file.Content), &manifest) != nil {
+			return
+		}
+		for k, v := range manifest.Dependencies {
+			detection.Dependencies[k] = v
+		}
+		for k, v := range manifest.DevDependencies {
+			detection.DevDependencies[k] = v
+		}
+		for k, v := range manifest.Scripts {
+			detection.Scripts[k] = v
+		}
+		if detection.Framework == "" {
+			if _, ok := manifest.Dependencies["express"]; ok {
+				detection.Framework = "express"
+			}
+		}
+		if detection.EntryPoint == "" && manifest.Main != "" {
+			detection.EntryPoint = manifest.Main
+		}
+		return
+	}
+}
+
+// archiveBaseName derives a project name from an archive path or file name.
+func archiveBaseName(name string) string {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(base), ext) {
+			return base[:len(base)-len(ext)]
+		}
+	}
+	if base == "." || base == "/" {
+		return ""
+	}
+	return base
+}
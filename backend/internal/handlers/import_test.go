@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -54,3 +57,82 @@ func TestImportGitHubRejectsPublicImportOnFreePlan(t *testing.T) {
 	require.NoError(t, db.Model(&models.Project{}).Count(&projectCount).Error)
 	require.Zero(t, projectCount)
 }
+
+func TestParseRepositoryURLRecognizesHosts(t *testing.T) {
+	cases := []struct {
+		url, provider string
+		want          repositoryRef
+	}{
+		{"https://gitlab.com/group/sub/app.git", "", repositoryRef{Provider: "gitlab", Host: "gitlab.com", Owner: "group/sub", Repo: "app"}},
+		{"gitlab.com/group/app/-/tree/main", "", repositoryRef{Provider: "gitlab", Host: "gitlab.com", Owner: "group", Repo: "app"}},
+		{"git@bitbucket.org:team/site.git", "", repositoryRef{Provider: "bitbucket", Host: "bitbucket.org", Owner: "team", Repo: "site"}},
+		{"https://bitbucket.org/team/site/src/main/", "", repositoryRef{Provider: "bitbucket", Host: "bitbucket.org", Owner: "team", Repo: "site"}},
+		{"https://github.com/apex-build/my.repo/tree/dev", "", repositoryRef{Provider: "github", Host: "github.com", Owner: "apex-build", Repo: "my.repo"}},
+		{"https://git.example.com/ops/tool", "gitlab", repositoryRef{Provider: "gitlab", Host: "git.example.com", Owner: "ops", Repo: "tool"}},
+	}
+	for _, tc := range cases {
+		ref, err := parseRepositoryURL(tc.url, tc.provider)
+		require.NoError(t, err, tc.url)
+		require.Equal(t, tc.want, *ref, tc.url)
+	}
+
+	for _, bad := range []string{"https://git.example.com/ops/tool", "https://bitbucket.org/team", "https://gitlab.com/group/../app"} {
+		_, err := parseRepositoryURL(bad, "")
+		require.Error(t, err, bad)
+	}
+}
+
+func TestImportArchiveURLCreatesProjectFromZip(t *testing.T) {
+	handler, userID, db := newImportHandlerTestFixture(t, "free")
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range map[string]string{
+		"site-main/package.json":           `{"main":"server.js","dependencies":{"express":"^4.19.0"},"scripts":{"start":"node server.js"}}`,
+		"site-main/server.js":              "require('express')().listen(3000)\n",
+		"site-main/node_modules/x/a.js":    "skipped",
+		"site-main/public/logo.png":        "\x89PNG\x00\x00",
+		"site-main/../site-main/notes.txt": "contained",
+	} {
+		entry, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = entry.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	}))
+	defer origin.Close()
+	// The default client refuses loopback addresses.
+	handler.httpClient = origin.Client()
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodPost, "/projects/import/archive", strings.NewReader(`{"url":"`+origin.URL+`/downloads/site-main.zip"}`))
+	context.Request.Header.Set("Content-Type", "application/json")
+	context.Set("user_id", userID)
+
+	handler.ImportArchiveURL(context)
+
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var response GitHubImportResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, "site-main", response.ProjectName)
+	require.Equal(t, "archive", response.Source)
+	require.Equal(t, "express", response.Framework)
+	require.Equal(t, 3, response.FileCount)
+	require.Equal(t, 1, response.SkippedFiles)
+
+	var paths []string
+	require.NoError(t, db.Model(&models.File{}).Where("project_id = ?", response.ProjectID).Order("path").Pluck("path", &paths).Error)
+	require.Equal(t, []string{"notes.txt", "package.json", "server.js"}, paths)
+
+	var project models.Project
+	require.NoError(t, db.First(&project, response.ProjectID).Error)
+	require.Equal(t, "server.js", project.EntryPoint)
+	require.Equal(t, "archive", project.Environment["import_source"])
+	scripts, _ := project.BuildConfig["scripts"].(map[string]interface{})
+	require.Equal(t, "node server.js", scripts["start"])
+}
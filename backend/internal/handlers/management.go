@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/management"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ManagementHandler serves management tokens to signed-in users and the
+// declarative management API used by the Terraform provider
+type ManagementHandler struct {
+	service *management.Service
+}
+
+// NewManagementHandler creates a new ManagementHandler
+func NewManagementHandler(service *management.Service) *ManagementHandler {
+	return &ManagementHandler{service: service}
+}
+
+func managementActor(c *gin.Context) management.Actor {
+	return management.Actor{UserID: c.GetUint("user_id"), Admin: c.GetBool("is_admin")}
+}
+
+// managementError maps service errors to status codes. Errors that are not
+// part of the API's vocabulary become a generic 500.
+func managementError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, management.ErrNotFound), errors.Is(err, management.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, management.ErrForbidden), errors.Is(err, management.ErrQuotaAboveCeiling):
+		status = http.StatusForbidden
+	case errors.Is(err, management.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, management.ErrConflict), errors.Is(err, management.ErrLastOwner),
+		errors.Is(err, management.ErrRoleInUse), errors.Is(err, management.ErrMemberLimit),
+		errors.Is(err, management.ErrReservedRole), errors.Is(err, management.ErrTokenLimit):
+		status = http.StatusConflict
+	}
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "Management request failed"
+	}
+	c.JSON(status, gin.H{"success": false, "error": message})
+}
+
+// managementApplied responds to an idempotent PUT: 201 when the resource
+// was created, 200 otherwise
+func managementApplied(c *gin.Context, data interface{}, created bool, err error) {
+	if err != nil {
+		managementError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"success": true, "data": data, "created": created})
+}
+
+func managementFetched(c *gin.Context, data interface{}, err error) {
+	if err != nil {
+		managementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+func managementDeleted(c *gin.Context, err error) {
+	if err != nil {
+		managementError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func bindManagementSpec(c *gin.Context, spec interface{}) bool {
+	if err := c.ShouldBindJSON(spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return false
+	}
+	return true
+}
+
+// ListManagementTokens returns the caller's management tokens.
+// GET /user/management-tokens
+func (h *ManagementHandler) ListManagementTokens(c *gin.Context) {
+	tokens, err := h.service.ListTokens(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"tokens": tokens}})
+}
+
+// CreateManagementToken issues a token. The plaintext is only returned here.
+// POST /user/management-tokens
+func (h *ManagementHandler) CreateManagementToken(c *gin.Context) {
+	var req struct {
+		Name          string `json:"name" binding:"required,max=100"`
+		ExpiresInDays int    `json:"expires_in_days" binding:"min=0,max=365"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "name is required; expires_in_days must be 0-365"})
+		return
+	}
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, plaintext, err := h.service.CreateToken(c.GetUint("user_id"), req.Name, ttl)
+	if err != nil {
+		managementError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"token": token, "secret": plaintext}})
+}
+
+// RevokeManagementToken revokes one of the caller's tokens.
+// DELETE /user/management-tokens/:id
+func (h *ManagementHandler) RevokeManagementToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid token id"})
+		return
+	}
+	managementDeleted(c, h.service.RevokeToken(c.GetUint("user_id"), uint(id)))
+}
+
+// GetOrganization returns an organization by slug.
+// GET /manage/organizations/:org
+func (h *ManagementHandler) GetOrganization(c *gin.Context) {
+	org, err := h.service.GetOrganization(managementActor(c), c.Param("org"))
+	managementFetched(c, org, err)
+}
+
+// PutOrganization creates or updates an organization.
+// PUT /manage/organizations/:org
+func (h *ManagementHandler) PutOrganization(c *gin.Context) {
+	var spec management.OrganizationSpec
+	if !bindManagementSpec(c, &spec) {
+		return
+	}
+	org, created, err := h.service.ApplyOrganization(managementActor(c), c.Param("org"), spec)
+	managementApplied(c, org, created, err)
+}
+
+// ListMembers returns an organization's members.
+// GET /manage/organizations/:org/members
+func (h *ManagementHandler) ListMembers(c *gin.Context) {
+	members, err := h.service.ListMembers(managementActor(c), c.Param("org"))
+	managementFetched(c, gin.H{"members": members}, err)
+}
+
+// GetMember returns a member by email.
+// GET /manage/organizations/:org/members/:email
+func (h *ManagementHandler) GetMember(c *gin.Context) {
+	member, err := h.service.GetMember(managementActor(c), c.Param("org"), c.Param("email"))
+	managementFetched(c, member, err)
+}
+
+// PutMember adds a member or changes their role.
+// PUT /manage/organizations/:org/members/:email
+func (h *ManagementHandler) PutMember(c *gin.Context) {
+	var spec management.MemberSpec
+	if !bindManagementSpec(c, &spec) {
+		return
+	}
+	member, created, err := h.service.ApplyMember(managementActor(c), c.Param("org"), c.Param("email"), spec)
+	managementApplied(c, member, created, err)
+}
+
+// DeleteMember removes a member.
+// DELETE /manage/organizations/:org/members/:email
+func (h *ManagementHandler) DeleteMember(c *gin.Context) {
+	managementDeleted(c, h.service.DeleteMember(managementActor(c), c.Param("org"), c.Param("email")))
+}
+
+// ListRoles returns an organization's roles.
+// GET /manage/organizations/:org/roles
+func (h *ManagementHandler) ListRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles(managementActor(c), c.Param("org"))
+	managementFetched(c, gin.H{"roles": roles}, err)
+}
+
+// GetRole returns a role by name.
+// GET /manage/organizations/:org/roles/:name
+func (h *ManagementHandler) GetRole(c *gin.Context) {
+	role, err := h.service.GetRole(managementActor(c), c.Param("org"), c.Param("name"))
+	managementFetched(c, role, err)
+}
+
+// PutRole creates or updates a custom role.
+// PUT /manage/organizations/:org/roles/:name
+func (h *ManagementHandler) PutRole(c *gin.Context) {
+	var spec management.RoleSpec
+	if !bindManagementSpec(c, &spec) {
+		return
+	}
+	role, created, err := h.service.ApplyRole(managementActor(c), c.Param("org"), c.Param("name"), spec)
+	managementApplied(c, role, created, err)
+}
+
+// DeleteRole deletes a custom role that no member holds.
+// DELETE /manage/organizations/:org/roles/:name
+func (h *ManagementHandler) DeleteRole(c *gin.Context) {
+	managementDeleted(c, h.service.DeleteRole(managementActor(c), c.Param("org"), c.Param("name")))
+}
+
+// GetQuotas returns an organization's limits.
+// GET /manage/organizations/:org/quotas
+func (h *ManagementHandler) GetQuotas(c *gin.Context) {
+	quotas, err := h.service.GetQuotas(managementActor(c), c.Param("org"))
+	managementFetched(c, quotas, err)
+}
+
+// PutQuotas sets an organization's limits.
+// PUT /manage/organizations/:org/quotas
+func (h *ManagementHandler) PutQuotas(c *gin.Context) {
+	var spec management.Quotas
+	if !bindManagementSpec(c, &spec) {
+		return
+	}
+	quotas, err := h.service.ApplyQuotas(managementActor(c), c.Param("org"), spec)
+	managementApplied(c, quotas, false, err)
+}
+
+// ListWebhooks returns an organization's webhooks.
+// GET /manage/organizations/:org/webhooks
+func (h *ManagementHandler) ListWebhooks(c *gin.Context) {
+	hooks, err := h.service.ListWebhooks(managementActor(c), c.Param("org"))
+	managementFetched(c, gin.H{"webhooks": hooks}, err)
+}
+
+// GetWebhook returns a webhook by external ID.
+// GET /manage/organizations/:org/webhooks/:webhookId
+func (h *ManagementHandler) GetWebhook(c *gin.Context) {
+	hook, err := h.service.GetWebhook(managementActor(c), c.Param("org"), c.Param("webhookId"))
+	managementFetched(c, hook, err)
+}
+
+// PutWebhook creates or updates a webhook.
+// PUT /manage/organizations/:org/webhooks/:webhookId
+func (h *ManagementHandler) PutWebhook(c *gin.Context) {
+	var spec management.WebhookSpec
+	if !bindManagementSpec(c, &spec) {
+		return
+	}
+	hook, created, err := h.service.ApplyWebhook(managementActor(c), c.Param("org"), c.Param("webhookId"), spec)
+	managementApplied(c, hook, created, err)
+}
+
+// DeleteWebhook deletes a webhook.
+// DELETE /manage/organizations/:org/webhooks/:webhookId
+func (h *ManagementHandler) DeleteWebhook(c *gin.Context) {
+	managementDeleted(c, h.service.DeleteWebhook(managementActor(c), c.Param("org"), c.Param("webhookId")))
+}
+
+// RegisterTokenRoutes registers token management on the session-authenticated group
+func (h *ManagementHandler) RegisterTokenRoutes(rg *gin.RouterGroup) {
+	rg.GET("/user/management-tokens", h.ListManagementTokens)
+	rg.POST("/user/management-tokens", h.CreateManagementToken)
+	rg.DELETE("/user/management-tokens/:id", h.RevokeManagementToken)
+}
+
+// RegisterRoutes registers the management API on a token-authenticated group
+func (h *ManagementHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	orgs := rg.Group("/organizations/:org")
+	orgs.GET("", h.GetOrganization)
+	orgs.PUT("", h.PutOrganization)
+	orgs.GET("/members", h.ListMembers)
+	orgs.GET("/members/:email", h.GetMember)
+	orgs.PUT("/members/:email", h.PutMember)
+	orgs.DELETE("/members/:email", h.DeleteMember)
+	orgs.GET("/roles", h.ListRoles)
+	orgs.GET("/roles/:name", h.GetRole)
+	orgs.PUT("/roles/:name", h.PutRole)
+	orgs.DELETE("/roles/:name", h.DeleteRole)
+	orgs.GET("/quotas", h.GetQuotas)
+	orgs.PUT("/quotas", h.PutQuotas)
+	orgs.GET("/webhooks", h.ListWebhooks)
+	orgs.GET("/webhooks/:webhookId", h.GetWebhook)
+	orgs.PUT("/webhooks/:webhookId", h.PutWebhook)
+	orgs.DELETE("/webhooks/:webhookId", h.DeleteWebhook)
+}
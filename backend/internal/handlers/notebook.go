@@ -0,0 +1,515 @@
+// APEX.BUILD Notebook API Handlers
+// REST API and WebSocket endpoints for notebook kernels and .ipynb files
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/middleware"
+	"apex-build/internal/notebook"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// NotebookHandler handles notebook kernel and file requests
+type NotebookHandler struct {
+	db       *gorm.DB
+	kernels  *notebook.Manager
+	upgrader websocket.Upgrader
+	access   collaboration.AccessResolver
+	quota    ExecutionQuota
+}
+
+// NewNotebookHandler creates a new notebook handler
+func NewNotebookHandler(db *gorm.DB, kernels *notebook.Manager) *NotebookHandler {
+	return &NotebookHandler{
+		db:      db,
+		kernels: kernels,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     allowedWebSocketOrigin,
+		},
+	}
+}
+
+// StartKernelRequest represents the request to start a notebook kernel
+type StartKernelRequest struct {
+	ProjectID uint   `json:"project_id" binding:"required"`
+	Language  string `json:"language"`
+}
+
+// ExecuteCellRequest represents a cell execution request. When Path names a
+// project notebook and CellID one of its cells, the outputs are saved into
+// the file.
+type ExecuteCellRequest struct {
+	Code   string `json:"code"`
+	CellID string `json:"cell_id"`
+	Path   string `json:"path"`
+}
+
+// SaveNotebookRequest represents the request to save a notebook file
+type SaveNotebookRequest struct {
+	Path     string          `json:"path" binding:"required"`
+	Notebook json.RawMessage `json:"notebook" binding:"required"`
+}
+
+// SetAccessResolver lets collaborators and organization members use
+// notebooks in projects they can reach, with their role
+func (h *NotebookHandler) SetAccessResolver(access collaboration.AccessResolver) {
+	h.access = access
+}
+
+// SetExecutionQuota charges cell runs against the execution minutes quota
+func (h *NotebookHandler) SetExecutionQuota(quota ExecutionQuota) {
+	h.quota = quota
+}
+
+// kernelForUser loads a kernel and checks it belongs to the caller.
+func (h *NotebookHandler) kernelForUser(c *gin.Context) (*notebook.Kernel, uint, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, 0, false
+	}
+	kernel, err := h.kernels.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kernel not found"})
+		return nil, 0, false
+	}
+	if kernel.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, 0, false
+	}
+	return kernel, userID, true
+}
+
+// StartKernel starts a persistent kernel for a project
+// POST /api/v1/notebooks/kernels
+func (h *NotebookHandler) StartKernel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req StartKernelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if _, ok := accessibleProjectByID(c, h.db, h.access, req.ProjectID, canEditProject); !ok {
+		return
+	}
+
+	kernel, err := h.kernels.Start(c.Request.Context(), userID, req.ProjectID, req.Language)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"kernel":     kernel.Info(),
+		"stream_url": "/ws/notebook/" + kernel.ID,
+	})
+}
+
+// ListKernels lists the caller's running kernels
+// GET /api/v1/notebooks/kernels
+func (h *NotebookHandler) ListKernels(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	kernels := []notebook.KernelInfo{}
+	for _, kernel := range h.kernels.ListForUser(userID) {
+		kernels = append(kernels, kernel.Info())
+	}
+	c.JSON(http.StatusOK, gin.H{"kernels": kernels})
+}
+
+// GetKernel returns a kernel's state
+// GET /api/v1/notebooks/kernels/:id
+func (h *NotebookHandler) GetKernel(c *gin.Context) {
+	kernel, _, ok := h.kernelForUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"kernel": kernel.Info()})
+}
+
+// ExecuteCell runs code in the kernel and returns its outputs. Outputs are
+// also streamed to /ws/notebook/:id subscribers as they arrive.
+// POST /api/v1/notebooks/kernels/:id/execute
+func (h *NotebookHandler) ExecuteCell(c *gin.Context) {
+	kernel, userID, ok := h.kernelForUser(c)
+	if !ok {
+		return
+	}
+	// Access may have been revoked, or the project trashed, since the
+	// kernel started
+	if _, ok := accessibleProjectByID(c, h.db, h.access, kernel.ProjectID, canEditProject); !ok {
+		return
+	}
+
+	var req ExecuteCellRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var file *models.File
+	var nb *notebook.Notebook
+	var cell *notebook.Cell
+	code := req.Code
+	if req.Path != "" {
+		var err error
+		file, nb, err = h.loadNotebook(kernel.ProjectID, req.Path)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if cell = nb.Cell(req.CellID); cell == nil || cell.CellType != notebook.CellCode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cell_id must name a code cell in the notebook"})
+			return
+		}
+		if strings.TrimSpace(code) == "" {
+			code = string(cell.Source)
+		}
+	}
+
+	// The kernel outlives the request, so each cell is charged on its own:
+	// reserve the longest it may run, then settle what it actually took.
+	reservationID := ""
+	if h.quota != nil {
+		if reservationID, ok = h.quota.ReserveExecution(c, h.kernels.ExecutionTimeout()); !ok {
+			return
+		}
+	}
+	started := time.Now()
+	result, err := h.kernels.Execute(c.Request.Context(), kernel.ID, req.CellID, code)
+	durationMs := time.Since(started).Milliseconds()
+	if result != nil && result.DurationMs > 0 {
+		durationMs = result.DurationMs
+	}
+	h.settleCell(reservationID, userID, kernel.ProjectID, durationMs)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, notebook.ErrKernelDead) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if cell != nil {
+		count := result.ExecutionCount
+		cell.Source = notebook.MultilineText(code)
+		cell.ExecutionCount = &count
+		cell.Outputs = result.Outputs
+		if err := h.saveNotebook(file, nb, userID, false); err != nil {
+			c.JSON(http.StatusOK, gin.H{"result": result, "saved": false, "save_error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": result, "saved": cell != nil})
+}
+
+// settleCell charges a cell run's measured time against its reservation
+func (h *NotebookHandler) settleCell(reservationID string, userID, projectID uint, durationMs int64) {
+	if h.quota == nil || reservationID == "" {
+		return
+	}
+	if err := h.quota.SettleExecution(context.Background(), reservationID, &projectID, durationMs, 0); err != nil {
+		log.Printf("usage tracker: failed to settle notebook cell usage for user %d: %v", userID, err)
+	}
+}
+
+// InterruptKernel interrupts the running cell, keeping kernel state
+// POST /api/v1/notebooks/kernels/:id/interrupt
+func (h *NotebookHandler) InterruptKernel(c *gin.Context) {
+	kernel, _, ok := h.kernelForUser(c)
+	if !ok {
+		return
+	}
+	if err := kernel.Interrupt(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Kernel interrupted"})
+}
+
+// RestartKernel restarts the kernel process, clearing its state
+// POST /api/v1/notebooks/kernels/:id/restart
+func (h *NotebookHandler) RestartKernel(c *gin.Context) {
+	kernel, _, ok := h.kernelForUser(c)
+	if !ok {
+		return
+	}
+	if _, err := h.kernels.Restart(c.Request.Context(), kernel.ID); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"kernel": kernel.Info()})
+}
+
+// ShutdownKernel stops a kernel
+// DELETE /api/v1/notebooks/kernels/:id
+func (h *NotebookHandler) ShutdownKernel(c *gin.Context) {
+	kernel, _, ok := h.kernelForUser(c)
+	if !ok {
+		return
+	}
+	if err := h.kernels.Shutdown(kernel.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Kernel shut down"})
+}
+
+// GetNotebook returns a parsed project notebook file
+// GET /api/v1/notebooks/projects/:projectId/file?path=analysis.ipynb
+func (h *NotebookHandler) GetNotebook(c *gin.Context) {
+	if _, exists := middleware.GetUserID(c); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	if _, ok := accessibleProjectByID(c, h.db, h.access, uint(projectID), nil); !ok {
+		return
+	}
+
+	file, nb, err := h.loadNotebook(uint(projectID), c.Query("path"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":  file.ID,
+		"path":     file.Path,
+		"language": nb.Language(),
+		"notebook": nb,
+	})
+}
+
+// SaveNotebook validates and stores a notebook as a project file, creating
+// it when missing
+// PUT /api/v1/notebooks/projects/:projectId/file
+func (h *NotebookHandler) SaveNotebook(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	if _, ok := accessibleProjectByID(c, h.db, h.access, uint(projectID), canEditProject); !ok {
+		return
+	}
+
+	var req SaveNotebookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	notebookPath, err := cleanNotebookPath(req.Path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	nb, err := notebook.Parse(req.Notebook)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var file models.File
+	err = h.db.Where("project_id = ? AND path = ?", uint(projectID), notebookPath).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		file = models.File{
+			ProjectID: uint(projectID),
+			Path:      notebookPath,
+			Name:      path.Base(notebookPath),
+			Type:      "file",
+			MimeType:  "application/x-ipynb+json",
+		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := h.saveNotebook(&file, nb, userID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save notebook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"file_id": file.ID, "path": file.Path, "notebook": nb})
+}
+
+// HandleNotebookWebSocket streams kernel status and cell output events
+// GET /ws/notebook/:kernelId
+func (h *NotebookHandler) HandleNotebookWebSocket(c *gin.Context) {
+	userID, err := websocketUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	kernel, err := h.kernels.Get(c.Param("kernelId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kernel not found"})
+		return
+	}
+	if kernel.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := kernel.Subscribe()
+	defer unsubscribe()
+
+	// Detect client disconnects; the stream is server-to-client only.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	info := kernel.Info()
+	if err := conn.WriteJSON(notebook.Event{KernelID: info.ID, Type: "status", Status: info.Status, Timestamp: info.LastActivity}); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// RegisterRoutes registers all notebook routes. meteredMiddlewares guard the
+// routes that start kernels or run code.
+func (h *NotebookHandler) RegisterRoutes(rg *gin.RouterGroup, meteredMiddlewares ...gin.HandlerFunc) {
+	nb := rg.Group("/notebooks")
+	{
+		metered := nb.Group("")
+		if len(meteredMiddlewares) > 0 {
+			metered.Use(meteredMiddlewares...)
+		}
+		metered.POST("/kernels", h.StartKernel)
+		metered.POST("/kernels/:id/execute", h.ExecuteCell)
+		metered.POST("/kernels/:id/restart", h.RestartKernel)
+		nb.GET("/kernels", h.ListKernels)
+		nb.GET("/kernels/:id", h.GetKernel)
+		nb.POST("/kernels/:id/interrupt", h.InterruptKernel)
+		nb.DELETE("/kernels/:id", h.ShutdownKernel)
+
+		nb.GET("/projects/:projectId/file", h.GetNotebook)
+		nb.PUT("/projects/:projectId/file", h.SaveNotebook)
+	}
+}
+
+func cleanNotebookPath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if !strings.HasSuffix(strings.ToLower(p), ".ipynb") {
+		return "", errors.New("notebook path must end in .ipynb")
+	}
+	for _, part := range strings.Split(strings.ReplaceAll(p, "\\", "/"), "/") {
+		if part == ".." {
+			return "", errors.New("invalid notebook path")
+		}
+	}
+	return p, nil
+}
+
+func (h *NotebookHandler) loadNotebook(projectID uint, notebookPath string) (*models.File, *notebook.Notebook, error) {
+	notebookPath, err := cleanNotebookPath(notebookPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file models.File
+	if err := h.db.Where("project_id = ? AND path = ?", projectID, notebookPath).First(&file).Error; err != nil {
+		return nil, nil, errors.New("notebook not found")
+	}
+	nb, err := notebook.Parse([]byte(file.Content))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &file, nb, nil
+}
+
+// saveNotebook writes the notebook into its project file. Explicit saves
+// create a file version; output updates from cell runs do not.
+func (h *NotebookHandler) saveNotebook(file *models.File, nb *notebook.Notebook, userID uint, createVersion bool) error {
+	content, err := notebook.Marshal(nb)
+	if err != nil {
+		return err
+	}
+	if file.ID == 0 {
+		file.Content = string(content)
+		file.Size = int64(len(content))
+		file.LastEditBy = userID
+		return h.db.Create(file).Error
+	}
+	if file.Content == string(content) {
+		return nil
+	}
+	if createVersion {
+		var user models.User
+		h.db.First(&user, userID)
+		previous := file.Content
+		file.Content = string(content)
+		file.Size = int64(len(content))
+		CreateFileVersion(h.db, file, userID, user.Username, "edit", "Notebook saved")
+		file.Content = previous
+	}
+	if err := h.db.Model(file).Updates(map[string]interface{}{
+		"content":      string(content),
+		"size":         int64(len(content)),
+		"last_edit_by": userID,
+		"version":      gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	file.Content = string(content)
+	return nil
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/notebook"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// echoKernel speaks the notebook driver protocol, answering every cell
+// with an immediate done message
+type echoKernel struct {
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+}
+
+func newEchoKernel() *echoKernel {
+	k := &echoKernel{}
+	k.stdinR, k.stdinW = io.Pipe()
+	k.stdoutR, k.stdoutW = io.Pipe()
+	go func() {
+		fmt.Fprintln(k.stdoutW, "\x1eAPEX-NB "+`{"type":"ready"}`)
+		scanner := bufio.NewScanner(k.stdinR)
+		for count := 1; scanner.Scan(); count++ {
+			var req struct{ ID string }
+			json.Unmarshal(scanner.Bytes(), &req)
+			fmt.Fprintf(k.stdoutW, "\x1eAPEX-NB {\"id\":%q,\"type\":\"done\",\"status\":\"ok\",\"execution_count\":%d}\n", req.ID, count)
+		}
+	}()
+	return k
+}
+
+func (k *echoKernel) Streams() (io.WriteCloser, io.Reader, io.Reader) {
+	return k.stdinW, k.stdoutR, nil
+}
+func (k *echoKernel) Interrupt() error { return nil }
+func (k *echoKernel) Kill() error      { return k.stdoutW.Close() }
+
+type fakeExecutionQuota struct {
+	reserved []time.Duration
+	settled  []string
+	deny     bool
+}
+
+func (q *fakeExecutionQuota) ReserveExecution(c *gin.Context, timeout time.Duration) (string, bool) {
+	if q.deny {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "quota exceeded"})
+		return "", false
+	}
+	q.reserved = append(q.reserved, timeout)
+	return fmt.Sprintf("res-%d", len(q.reserved)), true
+}
+
+func (q *fakeExecutionQuota) SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) error {
+	q.settled = append(q.settled, reservationID)
+	return nil
+}
+
+func (q *fakeExecutionQuota) ReleaseExecution(ctx context.Context, reservationID string) error {
+	return nil
+}
+
+func TestNotebookExecuteCellChargesEachCell(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Project{}, &models.File{}))
+	project := models.Project{Name: "nb", OwnerID: 1, Language: "python"}
+	trashed := models.Project{Name: "trashed", OwnerID: 1, Language: "python"}
+	require.NoError(t, db.Create(&project).Error)
+	require.NoError(t, db.Create(&trashed).Error)
+	require.NoError(t, db.Delete(&trashed).Error)
+
+	kernels := notebook.NewManager(func(context.Context, string, []string, map[string]string) (notebook.Process, error) {
+		return newEchoKernel(), nil
+	}, &notebook.Config{StartTimeout: time.Second, ExecutionTimeout: 2 * time.Minute})
+	t.Cleanup(kernels.Close)
+	quota := &fakeExecutionQuota{}
+	handler := NewNotebookHandler(db, kernels)
+	handler.SetExecutionQuota(quota)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", uint(1)); c.Next() })
+	handler.RegisterRoutes(router.Group(""))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	// A trashed project cannot start kernels
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/notebooks/kernels", fmt.Sprintf(`{"project_id":%d}`, trashed.ID)).Code)
+
+	started := do(http.MethodPost, "/notebooks/kernels", fmt.Sprintf(`{"project_id":%d}`, project.ID))
+	require.Equal(t, http.StatusCreated, started.Code, started.Body.String())
+	var startResp struct {
+		Kernel notebook.KernelInfo `json:"kernel"`
+	}
+	require.NoError(t, json.Unmarshal(started.Body.Bytes(), &startResp))
+	executePath := "/notebooks/kernels/" + startResp.Kernel.ID + "/execute"
+
+	for i := 0; i < 2; i++ {
+		recorder := do(http.MethodPost, executePath, `{"code":"1+1"}`)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	require.Equal(t, []time.Duration{2 * time.Minute, 2 * time.Minute}, quota.reserved)
+	require.Equal(t, []string{"res-1", "res-2"}, quota.settled)
+
+	// Reading kernel state is not charged, and runs stop once the quota is spent
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/notebooks/kernels/"+startResp.Kernel.ID, "").Code)
+	quota.deny = true
+	require.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, executePath, `{"code":"1+1"}`).Code)
+	require.Len(t, quota.reserved, 2)
+
+	// Trashing the project stops its running kernel from executing cells
+	quota.deny = false
+	require.NoError(t, db.Delete(&project).Error)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, executePath, `{"code":"1+1"}`).Code)
+	require.Len(t, quota.reserved, 2)
+}
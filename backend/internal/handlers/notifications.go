@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/notifications"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler serves the in-app notification center.
+type NotificationHandler struct {
+	service *notifications.Service
+}
+
+// NewNotificationHandler creates a new NotificationHandler.
+func NewNotificationHandler(service *notifications.Service) *NotificationHandler {
+	return &NotificationHandler{service: service}
+}
+
+// ListNotifications returns the authenticated user's notifications.
+// GET /notifications?unread=true&limit=50
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	items, unread, err := h.service.List(c.Request.Context(), userID, c.Query("unread") == "true", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"notifications": items,
+			"unread_count":  unread,
+		},
+	})
+}
+
+// MarkRead marks a single notification as read.
+// POST /notifications/:id/read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid notification id"})
+		return
+	}
+
+	if err := h.service.MarkRead(c.Request.Context(), userID, uint(id)); err != nil {
+		if errors.Is(err, notifications.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update notification"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// MarkAllRead marks every notification for the user as read.
+// POST /notifications/read-all
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	updated, err := h.service.MarkAllRead(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"updated": updated}})
+}
+
+// RegisterRoutes registers the notification center endpoints.
+func (h *NotificationHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	ng := rg.Group("/notifications")
+	{
+		ng.GET("", h.ListNotifications)
+		ng.POST("/read-all", h.MarkAllRead)
+		ng.POST("/:id/read", h.MarkRead)
+	}
+}
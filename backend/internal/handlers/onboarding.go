@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/onboarding"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OnboardingHandler serves a user's guided setup checklist and the
+// per-organization checklist admins customize.
+type OnboardingHandler struct {
+	service *onboarding.Service
+	rbac    *enterprise.RBACService
+}
+
+// NewOnboardingHandler creates a new OnboardingHandler. rbac may be nil, in
+// which case organization checklists cannot be edited.
+func NewOnboardingHandler(service *onboarding.Service, rbac *enterprise.RBACService) *OnboardingHandler {
+	return &OnboardingHandler{service: service, rbac: rbac}
+}
+
+// GetOnboarding returns the caller's checklist progress, provisioning the
+// sample project on first use.
+// GET /onboarding
+func (h *OnboardingHandler) GetOnboarding(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	progress, err := h.service.Progress(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load onboarding"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": progress})
+}
+
+// CompleteOnboardingStep ticks off a manual checklist step.
+// POST /onboarding/steps/:key/complete
+func (h *OnboardingHandler) CompleteOnboardingStep(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	progress, err := h.service.CompleteStep(userID, c.Param("key"))
+	switch {
+	case errors.Is(err, onboarding.ErrUnknownStep):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	case errors.Is(err, onboarding.ErrStepNotManual):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to complete onboarding step"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": progress})
+}
+
+// DismissOnboarding hides guided setup for the caller.
+// POST /onboarding/dismiss
+func (h *OnboardingHandler) DismissOnboarding(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	if err := h.service.Dismiss(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to dismiss onboarding"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// parseChecklistRequest checks that the caller may manage the organization.
+func (h *OnboardingHandler) parseChecklistRequest(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	if h.rbac == nil || !h.rbac.HasPermission(uint(orgID), userID, "organization", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Admin permission required"})
+		return 0, 0, false
+	}
+	return uint(orgID), userID, true
+}
+
+// GetOrganizationChecklist returns an organization's onboarding checklist.
+// GET /enterprise/organizations/:id/onboarding-checklist
+func (h *OnboardingHandler) GetOrganizationChecklist(c *gin.Context) {
+	orgID, _, ok := h.parseChecklistRequest(c)
+	if !ok {
+		return
+	}
+	checklist, customized, err := h.service.GetChecklist(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load onboarding checklist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"checklist": checklist, "customized": customized}})
+}
+
+// UpdateOrganizationChecklist replaces an organization's onboarding
+// checklist. Members pick it up the next time they load onboarding.
+// PUT /enterprise/organizations/:id/onboarding-checklist
+func (h *OnboardingHandler) UpdateOrganizationChecklist(c *gin.Context) {
+	orgID, userID, ok := h.parseChecklistRequest(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Steps            []onboarding.Step `json:"steps"`
+		SampleTemplateID string            `json:"sample_template_id"`
+		SkipSample       bool              `json:"skip_sample"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	checklist, err := h.service.SaveChecklist(orgID, userID, &onboarding.Checklist{
+		Steps:            req.Steps,
+		SampleTemplateID: req.SampleTemplateID,
+		SkipSample:       req.SkipSample,
+	})
+	if errors.Is(err, onboarding.ErrInvalidChecklist) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save onboarding checklist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"checklist": checklist, "customized": true}})
+}
+
+// ResetOrganizationChecklist restores the default checklist.
+// DELETE /enterprise/organizations/:id/onboarding-checklist
+func (h *OnboardingHandler) ResetOrganizationChecklist(c *gin.Context) {
+	orgID, _, ok := h.parseChecklistRequest(c)
+	if !ok {
+		return
+	}
+	if err := h.service.ResetChecklist(orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to reset onboarding checklist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RegisterRoutes registers onboarding endpoints on an authenticated group.
+func (h *OnboardingHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/onboarding", h.GetOnboarding)
+	rg.POST("/onboarding/steps/:key/complete", h.CompleteOnboardingStep)
+	rg.POST("/onboarding/dismiss", h.DismissOnboarding)
+
+	rg.GET("/enterprise/organizations/:id/onboarding-checklist", h.GetOrganizationChecklist)
+	rg.PUT("/enterprise/organizations/:id/onboarding-checklist", h.UpdateOrganizationChecklist)
+	rg.DELETE("/enterprise/organizations/:id/onboarding-checklist", h.ResetOrganizationChecklist)
+}
@@ -19,6 +19,7 @@ import (
 	"apex-build/internal/email"
 	"apex-build/internal/origins"
 	"apex-build/internal/payments"
+	"apex-build/internal/usage"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -30,6 +31,21 @@ type PaymentHandlers struct {
 	db            *gorm.DB
 	stripeService *payments.StripeService
 	emailService  *email.Service
+	planChanges   PlanChangeRecorder
+	referrals     ReferralConverter
+}
+
+// PlanChangeRecorder is notified when a user's plan changes so usage limits
+// can be prorated for the rest of the billing period. *usage.Tracker
+// satisfies it.
+type PlanChangeRecorder interface {
+	RecordPlanChange(ctx context.Context, userID uint, from, to usage.PlanType, at time.Time) error
+}
+
+// ReferralConverter issues referral rewards when a referred user first
+// pays. *referrals.Service satisfies it.
+type ReferralConverter interface {
+	RecordConversion(userID uint, plan string) error
 }
 
 // NewPaymentHandlers creates a new payment handlers instance
@@ -44,6 +60,29 @@ func NewPaymentHandlers(db *gorm.DB, stripeSecretKey string, emailSvc ...*email.
 	return ph
 }
 
+// SetPlanChangeRecorder wires plan-change tracking for usage proration.
+func (h *PaymentHandlers) SetPlanChangeRecorder(r PlanChangeRecorder) {
+	h.planChanges = r
+}
+
+// SetReferralConverter wires referral rewards to paid invoices.
+func (h *PaymentHandlers) SetReferralConverter(r ReferralConverter) {
+	h.referrals = r
+}
+
+// recordPlanChange reports a plan change; failures only cost proration.
+func (h *PaymentHandlers) recordPlanChange(userID uint, from, to string) {
+	if h.planChanges == nil || to == "" || from == to {
+		return
+	}
+	if from == "" {
+		from = string(payments.PlanFree)
+	}
+	if err := h.planChanges.RecordPlanChange(context.Background(), userID, usage.PlanType(from), usage.PlanType(to), time.Now()); err != nil {
+		log.Printf("Warning: failed to record plan change for user %d: %v", userID, err)
+	}
+}
+
 func isDuplicateInsertError(err error) bool {
 	if err == nil {
 		return false
@@ -379,10 +418,12 @@ func (h *PaymentHandlers) handleCheckoutCompleted(event *payments.WebhookEvent)
 		updates["subscription_type"] = string(event.PlanType)
 	}
 
+	previousPlan := user.SubscriptionType
 	if err := h.db.Model(&user).Updates(updates).Error; err != nil {
 		log.Printf("Failed to update user subscription: %v", err)
 		return fmt.Errorf("failed to update user subscription: %w", err)
 	}
+	h.recordPlanChange(user.ID, previousPlan, string(event.PlanType))
 
 	// Grant initial credits for the new subscription — invoice.paid will also fire,
 	// but only for the *first* subscription invoiced via checkout; subsequent renewals
@@ -467,10 +508,12 @@ func (h *PaymentHandlers) handleSubscriptionUpdate(event *payments.WebhookEvent)
 		updates["subscription_type"] = string(event.PlanType)
 	}
 
+	previousPlan := user.SubscriptionType
 	if err := h.db.Model(&user).Updates(updates).Error; err != nil {
 		log.Printf("Failed to update user subscription: %v", err)
 		return fmt.Errorf("failed to update subscription: %w", err)
 	}
+	h.recordPlanChange(user.ID, previousPlan, string(event.PlanType))
 
 	log.Printf("User %s subscription updated: status=%s, plan=%s", user.Email, event.Status, event.PlanType)
 	return nil
@@ -494,10 +537,12 @@ func (h *PaymentHandlers) handleSubscriptionDeleted(event *payments.WebhookEvent
 		"subscription_type":   string(payments.PlanFree),
 	}
 
+	previousPlan := user.SubscriptionType
 	if err := h.db.Model(&user).Updates(updates).Error; err != nil {
 		log.Printf("Failed to update user after subscription deletion: %v", err)
 		return fmt.Errorf("failed to downgrade user: %w", err)
 	}
+	h.recordPlanChange(user.ID, previousPlan, string(payments.PlanFree))
 
 	log.Printf("User %s downgraded to free plan", user.Email)
 	return nil
@@ -540,9 +585,20 @@ func (h *PaymentHandlers) handleInvoicePaid(event *payments.WebhookEvent) error
 	if strings.TrimSpace(event.SubscriptionID) != "" {
 		updates["subscription_id"] = event.SubscriptionID
 	}
+	previousPlan := user.SubscriptionType
 	if err := h.db.Model(&user).Updates(updates).Error; err != nil {
 		log.Printf("Failed to update subscription status: %v", err)
+	} else if newPlan, ok := updates["subscription_type"].(string); ok {
+		h.recordPlanChange(user.ID, previousPlan, newPlan)
+	}
+	// A paid invoice on a paid plan is a referral conversion; the referral
+	// service ignores users who were not referred or already converted
+	if h.referrals != nil && planType != payments.PlanFree && event.Amount > 0 {
+		if err := h.referrals.RecordConversion(user.ID, string(planType)); err != nil {
+			log.Printf("Referral conversion failed for user %d: %v (event=%s)", user.ID, err, event.EventID)
+		}
 	}
+
 	plan := payments.GetPlanByType(planType)
 	if plan == nil || plan.MonthlyCreditsUSD <= 0 {
 		log.Printf("No credits to allocate for plan %s (user=%s event=%s)", planType, user.Email, event.EventID)
@@ -1085,10 +1141,13 @@ func (h *PaymentHandlers) ChangePlan(c *gin.Context) {
 		return
 	}
 
-	h.db.Model(&user).Updates(map[string]interface{}{
+	previousPlan := user.SubscriptionType
+	if err := h.db.Model(&user).Updates(map[string]interface{}{
 		"subscription_type":   string(newPlanType),
 		"subscription_status": string(subInfo.Status),
-	})
+	}).Error; err == nil {
+		h.recordPlanChange(user.ID, previousPlan, string(newPlanType))
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
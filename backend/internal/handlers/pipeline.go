@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/pipeline"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PipelineHandler serves a project's deployment pipeline: per-environment
+// configuration, deploys, promotions and release history
+type PipelineHandler struct {
+	db      *gorm.DB
+	service *pipeline.Service
+	access  collaboration.AccessResolver
+}
+
+// NewPipelineHandler creates a new PipelineHandler
+func NewPipelineHandler(db *gorm.DB, service *pipeline.Service) *PipelineHandler {
+	return &PipelineHandler{db: db, service: service}
+}
+
+// SetAccessResolver lets collaborators and organization members in,
+// according to their permission on the project
+func (h *PipelineHandler) SetAccessResolver(access collaboration.AccessResolver) {
+	h.access = access
+}
+
+// RegisterRoutes registers the environment routes on the protected group
+func (h *PipelineHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	envs := rg.Group("/projects/:id/environments")
+	{
+		envs.GET("", h.ListEnvironments)
+		envs.PUT("/:env", h.ApplyEnvironment)
+		envs.DELETE("/:env", h.DeleteEnvironment)
+		envs.POST("/:env/deploy", h.Deploy)
+		envs.POST("/:env/promote", h.Promote)
+		envs.GET("/:env/releases", h.ListReleases)
+	}
+}
+
+// pipelineError maps pipeline errors to status codes
+func pipelineError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, pipeline.ErrEnvironmentMissing):
+		status = http.StatusNotFound
+	case errors.Is(err, pipeline.ErrUnknownEnvironment), errors.Is(err, pipeline.ErrUnknownTarget),
+		errors.Is(err, pipeline.ErrInvalidConfig), errors.Is(err, pipeline.ErrNoFiles):
+		status = http.StatusBadRequest
+	case errors.Is(err, pipeline.ErrReleaseInProgress), errors.Is(err, pipeline.ErrNothingToPromote):
+		status = http.StatusConflict
+	}
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "Environment request failed"
+	}
+	c.JSON(status, gin.H{"success": false, "error": message})
+}
+
+// ListEnvironments returns the project's environments with the release live
+// in each, plus the targets environments can deploy to
+// GET /api/v1/projects/:id/environments
+func (h *PipelineHandler) ListEnvironments(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	views, err := h.service.ListEnvironments(project.ID)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"environments": views,
+		"targets":      h.service.Targets(),
+	}})
+}
+
+// ApplyEnvironment creates or replaces an environment's configuration
+// PUT /api/v1/projects/:id/environments/:env
+func (h *PipelineHandler) ApplyEnvironment(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	var spec pipeline.EnvironmentSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	env, err := h.service.ApplyEnvironment(project, c.Param("env"), spec)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": env})
+}
+
+// DeleteEnvironment removes an environment. Its releases stay in history.
+// DELETE /api/v1/projects/:id/environments/:env
+func (h *PipelineHandler) DeleteEnvironment(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteEnvironment(project.ID, c.Param("env")); err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Environment deleted"})
+}
+
+// Deploy snapshots the project's current files and deploys them to the
+// environment. The release runs smoke checks once the target is live.
+// POST /api/v1/projects/:id/environments/:env/deploy
+func (h *PipelineHandler) Deploy(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok || !requirePaidBackendPlan(c, h.db, userID, "deployments") {
+		return
+	}
+	release, err := h.service.Deploy(c.Request.Context(), project, userID, c.Param("env"))
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": release})
+}
+
+// Promote deploys the artifact live in the source environment to this one.
+// The source defaults to the previous configured environment.
+// POST /api/v1/projects/:id/environments/:env/promote
+func (h *PipelineHandler) Promote(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok || !requirePaidBackendPlan(c, h.db, userID, "deployments") {
+		return
+	}
+	var req struct {
+		From string `json:"from"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+			return
+		}
+	}
+	release, err := h.service.Promote(c.Request.Context(), project, userID, req.From, c.Param("env"))
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": release})
+}
+
+// ListReleases returns an environment's release history, newest first
+// GET /api/v1/projects/:id/environments/:env/releases
+func (h *PipelineHandler) ListReleases(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canEditProject)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	releases, err := h.service.Releases(project.ID, c.Param("env"), limit)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": releases})
+}
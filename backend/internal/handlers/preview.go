@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -18,9 +19,12 @@ import (
 
 	"apex-build/internal/auth"
 	"apex-build/internal/bundler"
+	"apex-build/internal/envlock"
 	"apex-build/internal/metrics"
 	"apex-build/internal/mobile"
 	"apex-build/internal/preview"
+	"apex-build/internal/secrets"
+	"apex-build/internal/wsproxy"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -35,7 +39,13 @@ type PreviewHandler struct {
 	serverRunner   *preview.ServerRunner
 	bundlerService *bundler.Service
 	authService    *auth.AuthService
+	secrets        *secrets.SecretsManager
+	devCertPEM     []byte
 	requireSandbox bool
+	// errorSolver analyzes captured preview errors (optional)
+	errorSolver ErrorSolver
+	// environmentLocks verifies projects against apex.lock (optional)
+	environmentLocks *envlock.Service
 }
 
 // NewPreviewHandler creates a new preview handler
@@ -44,7 +54,7 @@ func NewPreviewHandler(db *gorm.DB, server *preview.PreviewServer, authService *
 		db:             db,
 		server:         server,
 		serverRunner:   preview.NewServerRunnerFromEnv(db),
-		bundlerService: bundler.NewService(db),
+		bundlerService: previewBundler(db, server),
 		authService:    authService,
 		requireSandbox: previewSandboxRequired(),
 	}
@@ -57,12 +67,21 @@ func NewPreviewHandlerWithFactory(db *gorm.DB, factory *preview.PreviewServerFac
 		server:         factory.GetProcessServer(),
 		factory:        factory,
 		serverRunner:   preview.NewServerRunnerFromEnv(db),
-		bundlerService: bundler.NewService(db),
+		bundlerService: previewBundler(db, factory.GetProcessServer()),
 		authService:    authService,
 		requireSandbox: previewSandboxRequired(),
 	}
 }
 
+// previewBundler shares the preview server's bundler, so builds, cache
+// invalidation and timings refer to the bundles previews actually serve
+func previewBundler(db *gorm.DB, server *preview.PreviewServer) *bundler.Service {
+	if server != nil && server.GetBundler() != nil {
+		return server.GetBundler()
+	}
+	return bundler.NewService(db)
+}
+
 // FeatureStatus summarizes preview subsystem readiness for health reporting.
 func (h *PreviewHandler) FeatureStatus() map[string]interface{} {
 	bundlerStatus := h.bundlerService.Status()
@@ -124,6 +143,10 @@ func (h *PreviewHandler) StartPreview(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if rejectArchivedProject(c, &project) {
+		return
+	}
+	environmentLock := verifyEnvironmentLock(h.environmentLocks, project.ID, userID, envlock.SourcePreview)
 
 	// Auto-detect framework if not specified
 	if req.Framework == "" {
@@ -142,7 +165,7 @@ func (h *PreviewHandler) StartPreview(c *gin.Context) {
 	}
 
 	if isNextPreviewFramework(req.Framework) {
-		previewStatus, serverStatus, startErr := h.startFrameworkRuntimePreview(c, req.ProjectID, req.EnvVars, previewRuntimeStartTimeout())
+		previewStatus, serverStatus, startErr := h.startFrameworkRuntimePreview(c, &project, req.EnvVars, previewRuntimeStartTimeout())
 		if startErr != nil {
 			metrics.RecordPreviewStart("frontend", "error", false)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": startErr.Error()})
@@ -157,6 +180,7 @@ func (h *PreviewHandler) StartPreview(c *gin.Context) {
 			"sandbox":          false,
 			"sandbox_degraded": h.sandboxFallbackActive(),
 			"runtime_preview":  true,
+			"environment_lock": environmentLock,
 		})
 		metrics.RecordPreviewStart("frontend", "success", false)
 		return
@@ -195,6 +219,7 @@ func (h *PreviewHandler) StartPreview(c *gin.Context) {
 		"message":          "Preview started successfully",
 		"sandbox":          req.Sandbox,
 		"sandbox_degraded": (h.sandboxFallbackActive() && !req.Sandbox) || fallbackDegraded,
+		"environment_lock": environmentLock,
 	}
 	if fallbackDegraded {
 		response["degraded"] = true
@@ -262,6 +287,10 @@ func (h *PreviewHandler) StartFullStackPreview(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if rejectArchivedProject(c, &project) {
+		return
+	}
+	environmentLock := verifyEnvironmentLock(h.environmentLocks, project.ID, userID, envlock.SourcePreview)
 
 	if req.Framework == "" {
 		req.Framework = h.detectFramework(req.ProjectID)
@@ -276,7 +305,7 @@ func (h *PreviewHandler) StartFullStackPreview(c *gin.Context) {
 
 	if isNextPreviewFramework(req.Framework) {
 		nextEnvVars := mergePreviewEnvVars(req.EnvVars, req.BackendEnvVars)
-		previewStatus, serverStatus, startErr := h.startFrameworkRuntimePreview(c, req.ProjectID, nextEnvVars, previewRuntimeStartTimeout())
+		previewStatus, serverStatus, startErr := h.startFrameworkRuntimePreview(c, &project, nextEnvVars, previewRuntimeStartTimeout())
 		if startErr != nil {
 			if req.RequireBackend {
 				metrics.RecordPreviewStart("fullstack", "error", false)
@@ -308,6 +337,7 @@ func (h *PreviewHandler) StartFullStackPreview(c *gin.Context) {
 				"sandbox":          fallbackSandbox,
 				"sandbox_degraded": h.sandboxFallbackActive() && !fallbackSandbox,
 				"runtime_preview":  false,
+				"environment_lock": environmentLock,
 			})
 			return
 		}
@@ -322,6 +352,7 @@ func (h *PreviewHandler) StartFullStackPreview(c *gin.Context) {
 			"sandbox":          false,
 			"sandbox_degraded": h.sandboxFallbackActive(),
 			"runtime_preview":  true,
+			"environment_lock": environmentLock,
 		})
 		metrics.RecordPreviewStart("fullstack", "success", false)
 		return
@@ -396,6 +427,7 @@ func (h *PreviewHandler) StartFullStackPreview(c *gin.Context) {
 			Command:   req.BackendCommand,
 			EnvVars:   req.BackendEnvVars,
 		}
+		h.injectProjectPreviewEnv(&project, serverConfig)
 		startCtx := c.Request.Context()
 		var cancel context.CancelFunc
 		if !req.RequireBackend {
@@ -454,6 +486,7 @@ response:
 		"message":          "Full-stack preview started",
 		"sandbox":          req.Sandbox,
 		"sandbox_degraded": (h.sandboxFallbackActive() && !req.Sandbox) || sandboxFallbackReason != "",
+		"environment_lock": environmentLock,
 	}
 	if h.factory != nil {
 		resp["docker_available"] = h.factory.IsDockerAvailable()
@@ -503,6 +536,9 @@ func (h *PreviewHandler) StartMobileExpoWebPreview(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if rejectArchivedProject(c, &project) {
+		return
+	}
 	if !strings.EqualFold(strings.TrimSpace(project.TargetPlatform), string(mobile.TargetPlatformMobileExpo)) &&
 		!strings.EqualFold(strings.TrimSpace(project.MobileFramework), string(mobile.MobileFrameworkExpoReactNative)) {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -536,7 +572,7 @@ func (h *PreviewHandler) StartMobileExpoWebPreview(c *gin.Context) {
 		"CI":                        "1",
 		"EXPO_NO_TELEMETRY":         "1",
 	}, req.EnvVars)
-	proc, err := h.serverRunner.Start(context.Background(), &preview.ServerConfig{
+	serverConfig := &preview.ServerConfig{
 		ProjectID:           req.ProjectID,
 		EntryFile:           "package.json",
 		Command:             "npm run web",
@@ -545,7 +581,9 @@ func (h *PreviewHandler) StartMobileExpoWebPreview(c *gin.Context) {
 		CleanupDir:          source.RootDir,
 		InstallDependencies: true,
 		ReadyTimeout:        previewRuntimeStartTimeout(),
-	})
+	}
+	h.injectProjectPreviewEnv(&project, serverConfig)
+	proc, err := h.serverRunner.Start(context.Background(), serverConfig)
 	if err != nil {
 		_ = h.updateMobilePreviewStatus(c.Request.Context(), project.ID, "failed")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -769,9 +807,35 @@ func (h *PreviewHandler) HotReload(c *gin.Context) {
 		return
 	}
 
+	// A running dev server gets the saved file in its workspace and pushes
+	// the update to the page over its HMR socket. Without an open socket
+	// the page has to reload to see the change.
+	devServer := false
+	if h.serverRunner != nil {
+		synced, err := h.serverRunner.SyncFile(req.ProjectID, req.FilePath, []byte(req.Content))
+		if err != nil {
+			log.Printf("hot reload: failed to sync %s into dev server for project %d: %v", req.FilePath, req.ProjectID, err)
+		}
+		devServer = synced
+	}
+	mode := "reload"
+	if devServer && previewHMRConnected(req.ProjectID) {
+		mode = "hmr"
+	}
+
 	// Block hot reload only when sandbox is actively enforced AND Docker is available.
 	// When Docker is unavailable (fallback mode), allow hot reload through the process preview path.
+	// Dev servers already run in the sandbox, so their updates still apply.
 	if h.requireSandbox && !h.sandboxFallbackActive() {
+		if devServer {
+			c.JSON(http.StatusOK, gin.H{
+				"success":    true,
+				"message":    "File synced to dev server",
+				"mode":       mode,
+				"dev_server": true,
+			})
+			return
+		}
 		c.JSON(http.StatusConflict, gin.H{"error": "hot reload is unavailable while secure sandbox preview is enforced; use refresh instead"})
 		return
 	}
@@ -782,8 +846,10 @@ func (h *PreviewHandler) HotReload(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Hot reload sent",
+		"success":    true,
+		"message":    "Hot reload sent",
+		"mode":       mode,
+		"dev_server": devServer,
 	})
 }
 
@@ -904,6 +970,10 @@ func (h *PreviewHandler) ProxyPreview(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if c.Param("path") == "/__apex_diagnostics" && c.Request.Method == http.MethodPost {
+		h.recordPreviewDiagnostics(c, project.ID)
+		return
+	}
 
 	useSandbox, sandboxErr := h.resolveRequestedPreviewSandbox(c.Query("sandbox") == "true" || c.Query("sandbox") == "1")
 	if sandboxErr != nil {
@@ -931,9 +1001,16 @@ func (h *PreviewHandler) ProxyPreview(c *gin.Context) {
 		}
 	}
 
+	prefix := fmt.Sprintf("/api/v1/preview/proxy/%d", projectID)
+	proxyOptions := parseEnvironmentConfig(&project).PreviewProxy
+	publicScheme, _ := previewPublicBase(c)
+
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	proxy.FlushInterval = -1
 	proxy.ModifyResponse = func(resp *http.Response) error {
+		if !proxyOptions.KeepCookies {
+			rewritePreviewSetCookies(resp.Header, prefix, publicScheme)
+		}
 		h.applyPreviewResponseHeaders(resp.Header, c.GetHeader("Origin"), strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/html"))
 		contentType := strings.ToLower(resp.Header.Get("Content-Type"))
 		isHTML := strings.Contains(contentType, "text/html")
@@ -942,6 +1019,7 @@ func (h *PreviewHandler) ProxyPreview(c *gin.Context) {
 			responsePath = resp.Request.URL.Path
 		}
 		isJavaScript := isPreviewJavaScriptResponse(contentType, responsePath)
+		rewritePreviewSourceMapHeaders(resp.Header, prefix, previewToken)
 		if !isHTML && !isJavaScript {
 			return nil
 		}
@@ -957,6 +1035,7 @@ func (h *PreviewHandler) ProxyPreview(c *gin.Context) {
 			rewritten = h.rewritePreviewHTMLForProxyWithBackend(string(originalBody), uint(projectID), backendProxyURL, previewToken)
 		} else {
 			rewritten = h.rewritePreviewJavaScriptForProxyWithPrefix(string(originalBody), h.buildProxyBaseURL(c, uint(projectID)), previewToken)
+			rewritten = rewritePreviewSourceMapComments(rewritten, prefix, previewToken)
 		}
 		resp.Body = io.NopCloser(bytes.NewBufferString(rewritten))
 		resp.ContentLength = int64(len(rewritten))
@@ -974,11 +1053,9 @@ func (h *PreviewHandler) ProxyPreview(c *gin.Context) {
 		req.URL.Scheme = targetURL.Scheme
 		req.URL.Host = targetURL.Host
 		req.Host = targetURL.Host
+		setPreviewForwardedHeaders(req, c, prefix, proxyOptions.PreservePrefix)
 
-		path := c.Param("path")
-		if path == "" {
-			path = "/"
-		}
+		path := previewUpstreamPath(prefix, c.Param("path"), proxyOptions.PreservePrefix)
 		req.URL.Path = path
 		req.URL.RawPath = path
 
@@ -990,6 +1067,12 @@ func (h *PreviewHandler) ProxyPreview(c *gin.Context) {
 		req.Header.Del("Accept-Encoding")
 	}
 
+	release, ok := preparePreviewWebSocket(c, proxy, fmt.Sprintf("preview:%d", projectID), uint(projectID))
+	if !ok {
+		return
+	}
+	defer release()
+
 	h.applyPreviewResponseHeaders(c.Writer.Header(), c.GetHeader("Origin"), false)
 	proxy.ServeHTTP(c.Writer, c.Request)
 }
@@ -1177,55 +1260,105 @@ func (h *PreviewHandler) buildProxyBaseURL(c *gin.Context, projectID uint) strin
 // is rewritten by a client-side script to hit this proxy URL instead.
 // GET/POST/etc /api/v1/preview/backend-proxy/:projectId/*path
 func (h *PreviewHandler) ProxyBackend(c *gin.Context) {
-	if !h.ensureBackendPreviewAvailable(c) {
+	project, ok := h.authorizeBackendProxy(c)
+	if !ok {
 		return
 	}
-	if h.handlePreviewCORS(c) {
+	projectID := project.ID
+
+	// Look up the running backend server port for this project
+	serverStatus := h.serverRunner.GetStatus(projectID)
+	if serverStatus == nil || !serverStatus.Running {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Backend server not running"})
 		return
 	}
 
+	targetURL, parseErr := backendProxyTargetURL(serverStatus)
+	if parseErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build backend proxy"})
+		return
+	}
+
+	h.serveBackendProxy(c, project, targetURL,
+		fmt.Sprintf("/api/v1/preview/backend-proxy/%d", projectID),
+		h.buildBackendProxyBaseURL(c, projectID),
+		fmt.Sprintf("backend:%d", projectID))
+}
+
+// ProxyPort proxies a port the backend server exposes by name, so a
+// frontend dev server and an API server can be previewed side by side.
+// GET/POST/etc /api/v1/preview/port-proxy/:projectId/:port/*path
+func (h *PreviewHandler) ProxyPort(c *gin.Context) {
+	project, ok := h.authorizeBackendProxy(c)
+	if !ok {
+		return
+	}
+	projectID := project.ID
+
+	name := c.Param("port")
+	target, err := h.serverRunner.PortURL(projectID, name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	targetURL, parseErr := url.Parse(target)
+	if parseErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build port proxy"})
+		return
+	}
+
+	scheme, host := previewPublicBase(c)
+	prefix := fmt.Sprintf("/api/v1/preview/port-proxy/%d/%s", projectID, name)
+	h.serveBackendProxy(c, project, targetURL, prefix, scheme+"://"+host+prefix,
+		fmt.Sprintf("port:%d:%s", projectID, name))
+}
+
+// authorizeBackendProxy checks backend preview availability, CORS and
+// project access for the backend proxies, returning the project.
+func (h *PreviewHandler) authorizeBackendProxy(c *gin.Context) (*models.Project, bool) {
+	if !h.ensureBackendPreviewAvailable(c) {
+		return nil, false
+	}
+	if h.handlePreviewCORS(c) {
+		return nil, false
+	}
+
 	projectIDStr := c.Param("projectId")
 	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
-		return
+		return nil, false
 	}
 
 	userID, resolveErr := h.resolvePreviewUserID(c, uint(projectID))
 	if resolveErr != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": resolveErr.Error()})
-		return
+		return nil, false
 	}
 	h.setPreviewAccessCookie(c, uint(projectID))
 
 	var project models.Project
 	if dbErr := h.db.First(&project, uint(projectID)).Error; dbErr != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
+		return nil, false
 	}
 	if project.OwnerID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
-	// Look up the running backend server port for this project
-	serverStatus := h.serverRunner.GetStatus(uint(projectID))
-	if serverStatus == nil || !serverStatus.Running {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Backend server not running"})
-		return
+		return nil, false
 	}
+	return &project, true
+}
 
-	targetURL, parseErr := backendProxyTargetURL(serverStatus)
-	if parseErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build backend proxy"})
-		return
-	}
+// serveBackendProxy forwards the request to a backend server address,
+// rewriting HTML and JavaScript so asset and API URLs stay under prefix.
+func (h *PreviewHandler) serveBackendProxy(c *gin.Context, project *models.Project, targetURL *url.URL, prefix, baseURL, wsKey string) {
+	projectID := project.ID
+	proxyOptions := parseEnvironmentConfig(project).PreviewProxy
+	publicScheme, _ := previewPublicBase(c)
 
-	// Strip the /backend-proxy/:projectId prefix — forward just the path
-	c.Request.URL.Path = c.Param("path")
-	if c.Request.URL.Path == "" {
-		c.Request.URL.Path = "/"
-	}
+	// Strip the proxy prefix — forward just the path — unless the app
+	// expects its base path
+	c.Request.URL.Path = previewUpstreamPath(prefix, c.Param("path"), proxyOptions.PreservePrefix)
 
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	proxy.FlushInterval = -1
@@ -1233,6 +1366,10 @@ func (h *PreviewHandler) ProxyBackend(c *gin.Context) {
 	proxy.Director = func(req *http.Request) {
 		baseDirector(req)
 		disablePreviewProxyCompression(req)
+		setPreviewForwardedHeaders(req, c, prefix, proxyOptions.PreservePrefix)
+		if wsproxy.IsUpgrade(req) {
+			stripPreviewTokenQuery(req)
+		}
 	}
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, proxyErr error) {
 		h.applyPreviewResponseHeaders(w.Header(), c.GetHeader("Origin"), false)
@@ -1241,6 +1378,9 @@ func (h *PreviewHandler) ProxyBackend(c *gin.Context) {
 		_, _ = w.Write([]byte(`{"error":"Backend proxy unavailable"}`))
 	}
 	proxy.ModifyResponse = func(resp *http.Response) error {
+		if !proxyOptions.KeepCookies {
+			rewritePreviewSetCookies(resp.Header, prefix, publicScheme)
+		}
 		contentType := strings.ToLower(resp.Header.Get("Content-Type"))
 		responsePath := ""
 		if resp.Request != nil && resp.Request.URL != nil {
@@ -1259,29 +1399,63 @@ func (h *PreviewHandler) ProxyBackend(c *gin.Context) {
 		}
 		_ = resp.Body.Close()
 
-		previewToken := h.issuePreviewAccessToken(c, uint(projectID))
+		previewToken := h.issuePreviewAccessToken(c, projectID)
 		var rewritten string
 		if isHTML {
 			rewritten = h.rewritePreviewHTMLForProxyWithPrefix(
 				string(originalBody),
-				fmt.Sprintf("/api/v1/preview/backend-proxy/%d", projectID),
-				h.buildBackendProxyURL(c, uint(projectID)),
+				prefix,
+				h.buildBackendProxyURL(c, projectID),
 				previewToken,
 			)
 		} else {
 			rewritten = h.rewritePreviewJavaScriptForProxyWithPrefix(
 				string(originalBody),
-				h.buildBackendProxyBaseURL(c, uint(projectID)),
+				baseURL,
 				previewToken,
 			)
 		}
 		setRewrittenPreviewResponseBody(resp, rewritten)
 		return nil
 	}
+	release, ok := preparePreviewWebSocket(c, proxy, wsKey, projectID)
+	if !ok {
+		return
+	}
+	defer release()
+
 	h.applyPreviewResponseHeaders(c.Writer.Header(), c.GetHeader("Origin"), false)
 	proxy.ServeHTTP(c.Writer, c.Request)
 }
 
+// maxPreviewWebSocketsPerProject caps open WebSockets (dev-server HMR,
+// app sockets) per project and proxy.
+const maxPreviewWebSocketsPerProject = 50
+
+var previewWebSockets = wsproxy.NewLimiter()
+
+// preparePreviewWebSocket readies a preview proxy for a WebSocket upgrade:
+// it takes a connection slot, counts dev server HMR sockets, and gives the
+// proxy an idle-timeout transport. Plain requests pass through untouched.
+// When ok is false the response has been written.
+func preparePreviewWebSocket(c *gin.Context, proxy *httputil.ReverseProxy, key string, projectID uint) (release func(), ok bool) {
+	if !wsproxy.IsUpgrade(c.Request) {
+		return func() {}, true
+	}
+	releaseSlot, ok := previewWebSockets.Acquire(key, maxPreviewWebSocketsPerProject)
+	if !ok {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many open WebSocket connections for this preview"})
+		return nil, false
+	}
+	releaseHMR := trackPreviewHMR(c.Request, c.Param("path"), projectID)
+	proxy.Transport = wsproxy.NewTransport(wsproxy.DefaultIdleTimeout)
+	return func() {
+		releaseHMR()
+		releaseSlot()
+	}, true
+}
+
 func disablePreviewProxyCompression(req *http.Request) {
 	if req == nil {
 		return
@@ -1374,6 +1548,8 @@ func (h *PreviewHandler) rewritePreviewHTMLForProxyWithPrefix(html string, prefi
 	replaced = strings.ReplaceAll(replaced, `url("`+prefix+`//`, `url("//`)
 	replaced = strings.ReplaceAll(replaced, `url('`+prefix+`//`, `url('//`)
 	replaced = strings.ReplaceAll(replaced, `url(`+prefix+`//`, `url(//`)
+	// Apps served with the prefix preserved already emit prefixed URLs.
+	replaced = strings.ReplaceAll(replaced, prefix+prefix, prefix)
 	replaced = appendPreviewTokenToProxyAssets(replaced, prefix, previewToken)
 
 	// Inject a script that patches fetch() / XHR to route localhost API calls through
@@ -1455,6 +1631,12 @@ func (h *PreviewHandler) rewritePreviewHTMLForProxyWithPrefix(html string, prefi
 		}
 	}
 
+	// Route dev server HMR sockets through the proxy so saved files apply
+	// without a reload
+	if prefix != "" {
+		replaced = injectPreviewHeadScript(replaced, previewHMRBridgeScript(prefix, previewToken))
+	}
+
 	return replaced
 }
 
@@ -1787,7 +1969,8 @@ func previewDurationFromEnv(key string, fallback time.Duration) time.Duration {
 	return time.Duration(ms) * time.Millisecond
 }
 
-func (h *PreviewHandler) startFrameworkRuntimePreview(c *gin.Context, projectID uint, envVars map[string]string, timeout time.Duration) (*preview.PreviewStatus, *preview.ServerStatus, error) {
+func (h *PreviewHandler) startFrameworkRuntimePreview(c *gin.Context, project *models.Project, envVars map[string]string, timeout time.Duration) (*preview.PreviewStatus, *preview.ServerStatus, error) {
+	projectID := project.ID
 	if !h.backendPreviewAvailable() {
 		reason := strings.TrimSpace(h.backendPreviewDisabledReason())
 		if reason == "" {
@@ -1799,11 +1982,13 @@ func (h *PreviewHandler) startFrameworkRuntimePreview(c *gin.Context, projectID
 	startCtx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	proc, err := h.serverRunner.Start(startCtx, &preview.ServerConfig{
+	serverConfig := &preview.ServerConfig{
 		ProjectID:    projectID,
 		EnvVars:      envVars,
 		ReadyTimeout: timeout,
-	})
+	}
+	h.injectProjectPreviewEnv(project, serverConfig)
+	proc, err := h.serverRunner.Start(startCtx, serverConfig)
 	if err != nil {
 		return nil, h.serverRunner.GetStatus(projectID), err
 	}
@@ -2173,6 +2358,7 @@ func (h *PreviewHandler) BuildProject(c *gin.Context) {
 		"hash":        result.Hash,
 		"js_size":     len(result.OutputJS),
 		"css_size":    len(result.OutputCSS),
+		"timing":      result.Timing,
 	})
 }
 
@@ -2186,6 +2372,34 @@ func (h *PreviewHandler) GetBundlerStatus(c *gin.Context) {
 		"available":   status.Available,
 		"version":     status.Version,
 		"cache_stats": status.CacheStats,
+		"metrics":     status.Metrics,
+		"workspaces":  status.Workspaces,
+	})
+}
+
+// GetBundleTimings returns a project's latest bundle timings
+// GET /api/v1/preview/bundler/timings/:projectId
+func (h *PreviewHandler) GetBundleTimings(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var project models.Project
+	if err := h.db.First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if project.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"timings": h.bundlerService.ProjectTimings(project.ID),
 	})
 }
 
@@ -2310,6 +2524,7 @@ func (h *PreviewHandler) StartServer(c *gin.Context) {
 		Command:   req.Command,
 		EnvVars:   req.EnvVars,
 	}
+	h.injectProjectPreviewEnv(&project, config)
 
 	// Backend preview processes are long-lived and must not be tied to request cancellation.
 	proc, err := h.serverRunner.Start(context.Background(), config)
@@ -2424,6 +2639,88 @@ func (h *PreviewHandler) GetServerStatus(c *gin.Context) {
 	})
 }
 
+// GetServerPorts lists the ports the backend server listens on and how
+// they are mapped
+// GET /api/v1/preview/server/ports/:projectId
+func (h *PreviewHandler) GetServerPorts(c *gin.Context) {
+	projectID, ok := h.authorizeServerPorts(c)
+	if !ok {
+		return
+	}
+
+	ports, err := h.serverRunner.DetectPorts(projectID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	h.respondServerPorts(c, projectID, ports)
+}
+
+// UpdateServerPorts sets which port the preview proxy targets and which
+// extra ports are exposed under /preview/port-proxy/:projectId/:name
+// PUT /api/v1/preview/server/ports/:projectId
+func (h *PreviewHandler) UpdateServerPorts(c *gin.Context) {
+	projectID, ok := h.authorizeServerPorts(c)
+	if !ok {
+		return
+	}
+
+	var mapping preview.PortMapping
+	if err := c.ShouldBindJSON(&mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	ports, err := h.serverRunner.SetPortMapping(projectID, mapping)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, preview.ErrServerNotRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	h.respondServerPorts(c, projectID, ports)
+}
+
+func (h *PreviewHandler) authorizeServerPorts(c *gin.Context) (uint, bool) {
+	if !h.ensureBackendPreviewAvailable(c) {
+		return 0, false
+	}
+	userID := c.GetUint("user_id")
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, false
+	}
+
+	var project models.Project
+	if err := h.db.First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return 0, false
+	}
+	if project.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return 0, false
+	}
+	return uint(projectID), true
+}
+
+func (h *PreviewHandler) respondServerPorts(c *gin.Context, projectID uint, ports []preview.ListeningPort) {
+	scheme, host := previewPublicBase(c)
+	urls := gin.H{"primary": h.buildBackendProxyURLForBrowser(c, projectID)}
+	status := h.serverRunner.GetStatus(projectID)
+	for name := range status.ExposedPorts {
+		urls[name] = fmt.Sprintf("%s://%s/api/v1/preview/port-proxy/%d/%s/", scheme, host, projectID, name)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ports":   ports,
+		"mapping": preview.PortMapping{PrimaryPort: status.PrimaryPort, Exposed: status.ExposedPorts},
+		"urls":    urls,
+	})
+}
+
 // GetServerLogs returns the logs of a backend server
 // GET /api/v1/preview/server/logs/:projectId
 func (h *PreviewHandler) GetServerLogs(c *gin.Context) {
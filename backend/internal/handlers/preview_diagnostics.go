@@ -0,0 +1,238 @@
+// Package handlers - Runtime diagnostics captured from live previews
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+	"apex-build/internal/hosting"
+	"apex-build/internal/preview"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// previewSourceMapCommentPattern matches a root-relative sourceMappingURL
+// comment at the end of a script line
+var previewSourceMapCommentPattern = regexp.MustCompile(`(?m)^(//[#@] sourceMappingURL=)(/[^/\s'"][^\s'"]*)[ \t]*$`)
+
+// SetErrorSolver enables Solver analysis of errors captured from previews
+func (h *PreviewHandler) SetErrorSolver(solver ErrorSolver) {
+	h.errorSolver = solver
+}
+
+// recordPreviewDiagnostics stores the errors and warnings the injected
+// preview script posts to /__apex_diagnostics through the proxy
+func (h *PreviewHandler) recordPreviewDiagnostics(c *gin.Context, projectID uint) {
+	reports, err := preview.DecodeDiagnosticReports(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.server.RecordDiagnostics(projectID, reports)
+	c.Status(http.StatusNoContent)
+}
+
+// authorizePreviewProject loads the :projectId project and checks the
+// caller owns it
+func (h *PreviewHandler) authorizePreviewProject(c *gin.Context) (*models.Project, bool) {
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return nil, false
+	}
+
+	var project models.Project
+	if err := h.db.First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	if project.OwnerID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return &project, true
+}
+
+// GetPreviewDiagnostics returns the runtime errors and warnings captured
+// from a project's preview, with bundle frames mapped to original sources
+// GET /api/v1/preview/diagnostics/:projectId
+func (h *PreviewHandler) GetPreviewDiagnostics(c *gin.Context) {
+	project, ok := h.authorizePreviewProject(c)
+	if !ok {
+		return
+	}
+	var since uint64
+	if raw := c.Query("since"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter"})
+			return
+		}
+		since = parsed
+	}
+
+	diagnostics := h.server.Diagnostics(project.ID, since)
+	counts := map[string]int{"error": 0, "warn": 0}
+	for _, diagnostic := range diagnostics {
+		counts[diagnostic.Level] += diagnostic.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"diagnostics":  diagnostics,
+		"counts":       counts,
+		"solver_ready": h.errorSolver != nil,
+	})
+}
+
+// ClearPreviewDiagnostics drops the diagnostics captured from a preview
+// DELETE /api/v1/preview/diagnostics/:projectId
+func (h *PreviewHandler) ClearPreviewDiagnostics(c *gin.Context) {
+	project, ok := h.authorizePreviewProject(c)
+	if !ok {
+		return
+	}
+	h.server.ClearDiagnostics(project.ID)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SolvePreviewDiagnostic asks the Solver for a root cause and fix of a
+// captured preview error, from its mapped stack and the project files in it.
+// Nothing is written; the fix can be applied from the editor. The call is
+// charged to the caller like any other AI request.
+// POST /api/v1/preview/diagnostics/:projectId/:diagnosticId/solve
+func (h *PreviewHandler) SolvePreviewDiagnostic(c *gin.Context) {
+	project, ok := h.authorizePreviewProject(c)
+	if !ok {
+		return
+	}
+	diagnosticID, err := strconv.ParseUint(c.Param("diagnosticId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid diagnostic ID"})
+		return
+	}
+	if h.errorSolver == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI error analysis is not available"})
+		return
+	}
+	diagnostic, found := h.server.Diagnostic(project.ID, diagnosticID)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Diagnostic not found"})
+		return
+	}
+
+	var files []models.File
+	h.db.Select("path", "content").Where("project_id = ? AND type = ?", project.ID, "file").Find(&files)
+	sources := errorSourceFiles(diagnosticErrorFrames(diagnostic.Frames), files)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	resp, err := h.errorSolver.Generate(ctx, &ai.AIRequest{
+		ID:          uuid.New().String(),
+		Capability:  ai.CapabilityDebugging,
+		Prompt:      previewSolverPrompt(&diagnostic, sources),
+		Temperature: 0.2,
+		UserID:      strconv.Itoa(int(c.GetUint("user_id"))),
+		ProjectID:   strconv.Itoa(int(project.ID)),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		respondAIFailure(c, err, "AI analysis failed. Please try again.")
+		return
+	}
+
+	paths := make([]string, 0, len(sources))
+	for _, file := range sources {
+		paths = append(paths, file.Path)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"analysis": resp.Content,
+		"files":    paths,
+		"provider": resp.Provider,
+	})
+}
+
+// diagnosticErrorFrames converts browser frames, newest first, to error
+// frames, oldest first, at their original positions
+func diagnosticErrorFrames(frames []preview.DiagnosticFrame) []hosting.ErrorFrame {
+	converted := make([]hosting.ErrorFrame, 0, len(frames))
+	for i := len(frames) - 1; i >= 0; i-- {
+		file, line, column := frames[i].Location()
+		converted = append(converted, hosting.ErrorFrame{
+			Filename: file,
+			Function: frames[i].Function,
+			Line:     line,
+			Column:   column,
+			InApp:    file != "" && !strings.Contains(file, "node_modules/") && !strings.HasSuffix(file, "__apex_bundle.js"),
+		})
+	}
+	return converted
+}
+
+func previewSolverPrompt(diagnostic *preview.Diagnostic, sources []models.File) string {
+	var b strings.Builder
+	b.WriteString("You are the APEX Solver agent diagnosing a runtime error in the live preview of an app under development.\n\n")
+	switch diagnostic.Kind {
+	case preview.DiagnosticKindRejection:
+		b.WriteString("Unhandled promise rejection: ")
+	case preview.DiagnosticKindConsole:
+		fmt.Fprintf(&b, "console.%s: ", diagnostic.Level)
+	default:
+		b.WriteString("Uncaught error: ")
+	}
+	fmt.Fprintf(&b, "%s\nSeen %d times", diagnostic.Message, diagnostic.Count)
+	if diagnostic.PageURL != "" {
+		fmt.Fprintf(&b, ", latest at %s", diagnostic.PageURL)
+	}
+	b.WriteString(".\n")
+	if len(diagnostic.Frames) > 0 {
+		b.WriteString("\nStack trace, mapped to original sources (innermost call first):\n")
+		for _, frame := range diagnostic.Frames {
+			file, line, column := frame.Location()
+			fmt.Fprintf(&b, "  %s (%s:%d:%d)\n", frame.Function, file, line, column)
+		}
+	}
+	for _, file := range sources {
+		content := file.Content
+		if len(content) > maxSolverSourceBytes {
+			content = content[:maxSolverSourceBytes] + "\n... (truncated)"
+		}
+		fmt.Fprintf(&b, "\nFile %s:\n```\n%s\n```\n", file.Path, content)
+	}
+	b.WriteString("\nExplain the root cause, then give the smallest code change that fixes it, ")
+	b.WriteString("as complete replacement snippets with their file paths. ")
+	b.WriteString("If the stack does not point at the cause, say what to log to find it.")
+	return b.String()
+}
+
+// rewritePreviewSourceMapComments points root-relative sourceMappingURL
+// comments at the proxy. Relative and data: URLs already resolve.
+func rewritePreviewSourceMapComments(js string, prefix string, previewToken string) string {
+	return previewSourceMapCommentPattern.ReplaceAllStringFunc(js, func(match string) string {
+		parts := previewSourceMapCommentPattern.FindStringSubmatch(match)
+		if strings.HasPrefix(parts[2], prefix+"/") {
+			return match
+		}
+		return parts[1] + appendPreviewTokenToProxyTarget(prefix+parts[2], prefix, previewToken)
+	})
+}
+
+// rewritePreviewSourceMapHeaders points root-relative SourceMap headers at
+// the proxy
+func rewritePreviewSourceMapHeaders(header http.Header, prefix string, previewToken string) {
+	for _, name := range []string{"SourceMap", "X-SourceMap"} {
+		value := header.Get(name)
+		if strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") && !strings.HasPrefix(value, prefix+"/") {
+			header.Set(name, appendPreviewTokenToProxyTarget(prefix+value, prefix, previewToken))
+		}
+	}
+}
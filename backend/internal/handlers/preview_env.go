@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/internal/preview"
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+)
+
+// previewReservedEnv are set by the server runner itself and never
+// overridden from project settings.
+var previewReservedEnv = map[string]bool{
+	"PORT": true,
+	"HOST": true,
+}
+
+// previewProdOnlyEnvPatterns match variables that belong to production
+// deploys only: live payment keys and explicitly prod-scoped values.
+var previewProdOnlyEnvPatterns = []string{
+	"PROD_*",
+	"*_PROD",
+	"*_PROD_*",
+	"PRODUCTION_*",
+	"*_PRODUCTION",
+	"*_PRODUCTION_*",
+	"*_LIVE_*",
+	"LIVE_*",
+	"*_LIVE",
+}
+
+// SetSecretsManager lets preview processes receive the project's
+// environment secrets.
+func (h *PreviewHandler) SetSecretsManager(manager *secrets.SecretsManager) {
+	h.secrets = manager
+}
+
+// SetLogForwarder forwards preview backend output to the project's log drains
+func (h *PreviewHandler) SetLogForwarder(forwarder preview.LogForwarder) {
+	if h.serverRunner != nil {
+		h.serverRunner.SetLogForwarder(forwarder)
+	}
+}
+
+// previewEnvExcluded reports whether name stays out of preview processes.
+func previewEnvExcluded(name string, extra []string) bool {
+	upper := strings.ToUpper(name)
+	if previewReservedEnv[upper] {
+		return true
+	}
+	for _, pattern := range previewProdOnlyEnvPatterns {
+		if ok, _ := path.Match(pattern, upper); ok {
+			return true
+		}
+	}
+	for _, pattern := range extra {
+		if ok, _ := path.Match(strings.ToUpper(strings.TrimSpace(pattern)), upper); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// projectPreviewEnv collects the dev-tier variables for a project: the
+// non-secret env vars from its environment config, then its environment
+// secrets (project-scoped over account-wide). Prod-only and excluded names
+// are dropped.
+func (h *PreviewHandler) projectPreviewEnv(project *models.Project) map[string]string {
+	config := parseEnvironmentConfig(project)
+	env := make(map[string]string)
+	for name, value := range config.EnvVars {
+		if !previewEnvExcluded(name, config.PreviewExcludeEnv) {
+			env[name] = value
+		}
+	}
+	if h.secrets == nil {
+		return env
+	}
+
+	var secretList []secrets.Secret
+	if err := h.db.Where("user_id = ? AND (project_id = ? OR project_id IS NULL)", project.OwnerID, project.ID).
+		Where("type = ?", secrets.SecretTypeEnvironment).
+		Order("project_id IS NOT NULL, id").
+		Find(&secretList).Error; err != nil {
+		return env
+	}
+	now := time.Now()
+	for _, secret := range secretList {
+		if previewEnvExcluded(secret.Name, config.PreviewExcludeEnv) {
+			continue
+		}
+		value, err := h.secrets.Decrypt(project.OwnerID, secret.EncryptedValue, secret.Salt)
+		if err != nil {
+			continue
+		}
+		env[secret.Name] = value
+		h.db.Model(&secrets.Secret{}).Where("id = ?", secret.ID).Update("last_accessed", now)
+	}
+	return env
+}
+
+// injectProjectPreviewEnv adds the project's dev-tier variables to a
+// server config. Variables passed in the request win over project ones.
+func (h *PreviewHandler) injectProjectPreviewEnv(project *models.Project, config *preview.ServerConfig) {
+	projectEnv := h.projectPreviewEnv(project)
+	if len(projectEnv) == 0 {
+		return
+	}
+
+	injected := make([]string, 0, len(projectEnv))
+	for name := range projectEnv {
+		if _, override := config.EnvVars[name]; !override {
+			injected = append(injected, name)
+		}
+	}
+	sort.Strings(injected)
+	config.EnvVars = mergePreviewEnvVars(projectEnv, config.EnvVars)
+	config.InjectedEnv = injected
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setPreviewForwardedHeaders tells the app the public address it is served
+// under, so it can build absolute URLs and decide on secure cookies. The
+// Host header stays the upstream's: dev servers such as Vite reject
+// unknown hosts.
+func setPreviewForwardedHeaders(req *http.Request, c *gin.Context, prefix string, preservePrefix bool) {
+	scheme, host := previewPublicBase(c)
+	req.Header.Set("X-Forwarded-Proto", scheme)
+	req.Header.Set("X-Forwarded-Host", host)
+	if preservePrefix {
+		req.Header.Del("X-Forwarded-Prefix")
+	} else {
+		req.Header.Set("X-Forwarded-Prefix", prefix)
+	}
+
+	forwarded := fmt.Sprintf("host=%q;proto=%s", host, scheme)
+	if clientIP, _, err := net.SplitHostPort(c.Request.RemoteAddr); err == nil {
+		if strings.Contains(clientIP, ":") {
+			clientIP = "[" + clientIP + "]"
+		}
+		forwarded = fmt.Sprintf("for=%q;", clientIP) + forwarded
+	}
+	req.Header.Set("Forwarded", forwarded)
+}
+
+// previewUpstreamPath returns the path sent to the app: the part after the
+// proxy prefix, or the full path when the app expects its base path.
+func previewUpstreamPath(prefix, path string, preservePrefix bool) string {
+	if path == "" {
+		path = "/"
+	}
+	if preservePrefix {
+		return prefix + path
+	}
+	return path
+}
+
+// rewritePreviewSetCookies scopes app cookies to the preview: the Domain
+// is dropped so the cookie binds to the public host, and paths are moved
+// under the proxy prefix so previews on the same host do not share
+// cookies. Over plain HTTP the Secure flag is dropped so the browser keeps
+// the cookie, the way it would on a production HTTPS origin.
+func rewritePreviewSetCookies(header http.Header, prefix, publicScheme string) {
+	values := header.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+	header.Del("Set-Cookie")
+	for _, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil {
+			header.Add("Set-Cookie", value)
+			continue
+		}
+		cookie.Domain = ""
+		if cookie.Path != "" && !strings.HasPrefix(cookie.Path, prefix) {
+			cookie.Path = strings.TrimSuffix(prefix+cookie.Path, "/")
+		}
+		if publicScheme != "https" && cookie.Secure {
+			cookie.Secure = false
+			if cookie.SameSite == http.SameSiteNoneMode {
+				cookie.SameSite = http.SameSiteLaxMode
+			}
+		}
+		if rewritten := cookie.String(); rewritten != "" {
+			header.Add("Set-Cookie", rewritten)
+		} else {
+			header.Add("Set-Cookie", value)
+		}
+	}
+}
+
+// SetDevCertificate publishes the CA that signs the preview TLS listener's
+// dev certificate, so users can trust it locally.
+func (h *PreviewHandler) SetDevCertificate(caPEM []byte) {
+	h.devCertPEM = caPEM
+}
+
+// GetDevCertificate downloads the preview dev CA certificate
+// GET /api/v1/preview/dev-cert
+func (h *PreviewHandler) GetDevCertificate(c *gin.Context) {
+	if len(h.devCertPEM) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preview TLS is not using a dev certificate"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="apex-preview-dev-ca.pem"`)
+	c.Data(http.StatusOK, "application/x-pem-file", h.devCertPEM)
+}
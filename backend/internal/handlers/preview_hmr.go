@@ -0,0 +1,142 @@
+// Package handlers - Hot module replacement bridging for live previews
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"apex-build/internal/wsproxy"
+)
+
+// previewHMRPaths are the WebSocket endpoints dev servers push module
+// updates on: Next.js, webpack-hot-middleware, webpack-dev-server 3 and
+// Metro. Vite is recognized by its subprotocol instead, since its endpoint
+// is the app's base path.
+var previewHMRPaths = []string{"/_next/webpack-hmr", "/__webpack_hmr", "/sockjs-node", "/hot"}
+
+// previewHMRSockets counts each project's open HMR sockets across the
+// preview proxies
+var previewHMRSockets = wsproxy.NewLimiter()
+
+// isPreviewHMRUpgrade reports whether a WebSocket upgrade, with the proxy
+// prefix already stripped from upstreamPath, is a dev server's HMR socket
+func isPreviewHMRUpgrade(req *http.Request, upstreamPath string) bool {
+	for _, protocol := range strings.Split(req.Header.Get("Sec-WebSocket-Protocol"), ",") {
+		if strings.TrimSpace(protocol) == "vite-hmr" {
+			return true
+		}
+	}
+	for _, hmrPath := range previewHMRPaths {
+		if upstreamPath == hmrPath || strings.HasPrefix(upstreamPath, hmrPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// trackPreviewHMR counts an HMR socket for the project until release is
+// called. Other upgrades are not counted.
+func trackPreviewHMR(req *http.Request, upstreamPath string, projectID uint) (release func()) {
+	if !isPreviewHMRUpgrade(req, upstreamPath) {
+		return func() {}
+	}
+	release, _ = previewHMRSockets.Acquire(previewHMRKey(projectID), 0)
+	return release
+}
+
+// previewHMRConnected reports whether a page of the project's preview has a
+// dev server HMR socket open, so saved files apply without a reload
+func previewHMRConnected(projectID uint) bool {
+	return previewHMRSockets.Active(previewHMRKey(projectID)) > 0
+}
+
+func previewHMRKey(projectID uint) string {
+	return fmt.Sprintf("hmr:%d", projectID)
+}
+
+// previewHMRBridgeScript patches WebSocket in a proxied page so a dev
+// server's HMR client, which connects to the page origin's root, reaches
+// the dev server through the proxy prefix. It runs before the page's own
+// scripts and tells the IDE whether HMR is connected.
+func previewHMRBridgeScript(prefix string, previewToken string) string {
+	return fmt.Sprintf(`<script>
+(function(){
+  if(window.__APEX_HMR_BRIDGE__||!window.WebSocket)return;
+  window.__APEX_HMR_BRIDGE__=true;
+  var _px=%q;
+  var _pt=%q;
+  var _paths=['/_next/webpack-hmr','/__webpack_hmr','/sockjs-node','/ws','/hot','/message'];
+  var _local=/^(localhost|127\.0\.0\.1|0\.0\.0\.0)$/;
+  var _OWS=window.WebSocket;
+  function _isHMR(path,protocols){
+    var p=[].concat(protocols||[]);
+    if(p.indexOf('vite-hmr')>=0||p.indexOf('vite-ping')>=0)return true;
+    for(var i=0;i<_paths.length;i++){
+      if(path===_paths[i]||path.indexOf(_paths[i]+'/')===0)return true;
+    }
+    return false;
+  }
+  function _bridge(u,protocols){
+    try{
+      var url=new URL(u,window.location.href);
+      if(url.hostname!==window.location.hostname&&!_local.test(url.hostname))return null;
+      if(url.pathname===_px||url.pathname.indexOf(_px+'/')===0)return null;
+      if(!_isHMR(url.pathname,protocols))return null;
+      url.protocol=window.location.protocol==='https:'?'wss:':'ws:';
+      url.host=window.location.host;
+      url.pathname=_px+url.pathname;
+      if(_pt)url.searchParams.set('preview_token',_pt);
+      return url.toString();
+    }catch(_e){
+      return null;
+    }
+  }
+  function _notify(status){
+    try{window.parent.postMessage({type:'apex-hmr',status:status},'*');}catch(_e){}
+  }
+  function BridgedWebSocket(u,protocols){
+    var target=_bridge(String(u),protocols);
+    var ws=protocols===undefined?new _OWS(target||u):new _OWS(target||u,protocols);
+    if(target){
+      var opened=false;
+      ws.addEventListener('open',function(){opened=true;_notify('connected');});
+      ws.addEventListener('close',function(){_notify(opened?'disconnected':'failed');});
+    }
+    return ws;
+  }
+  BridgedWebSocket.prototype=_OWS.prototype;
+  ['CONNECTING','OPEN','CLOSING','CLOSED'].forEach(function(k){BridgedWebSocket[k]=_OWS[k];});
+  window.WebSocket=BridgedWebSocket;
+})();
+</script>`, prefix, previewToken)
+}
+
+// injectPreviewHeadScript inserts a script at the start of <head>, ahead of
+// the page's own scripts
+func injectPreviewHeadScript(html string, script string) string {
+	for _, pattern := range []*regexp.Regexp{previewHeadTagPattern, previewBodyTagPattern} {
+		if loc := pattern.FindStringIndex(html); loc != nil {
+			return html[:loc[1]] + script + html[loc[1]:]
+		}
+	}
+	return script + html
+}
+
+var (
+	previewHeadTagPattern = regexp.MustCompile(`(?i)<head(?:\s[^>]*)?>`)
+	previewBodyTagPattern = regexp.MustCompile(`(?i)<body(?:\s[^>]*)?>`)
+)
+
+// stripPreviewTokenQuery drops APEX auth parameters from a proxied dev
+// server upgrade, since Vite only accepts its HMR socket on the bare base path
+func stripPreviewTokenQuery(req *http.Request) {
+	query := req.URL.Query()
+	if !query.Has("preview_token") && !query.Has("token") {
+		return
+	}
+	query.Del("token")
+	query.Del("preview_token")
+	req.URL.RawQuery = query.Encode()
+}
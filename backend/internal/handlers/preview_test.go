@@ -16,8 +16,10 @@ import (
 	"time"
 	"unsafe"
 
+	"apex-build/internal/hosting"
 	"apex-build/internal/mobile"
 	"apex-build/internal/preview"
+	"apex-build/internal/secrets"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -860,3 +862,210 @@ func TestMergePreviewEnvVarsOverlaysBackendValues(t *testing.T) {
 	require.Equal(t, "secret", merged["API_SECRET"])
 	require.Equal(t, "backend", merged["SHARED"])
 }
+
+func TestPreviewHandlerInjectsProjectEnvIntoServerProcesses(t *testing.T) {
+	handler, projectID := newPreviewHandlerTestFixture(t, false)
+	require.NoError(t, handler.db.AutoMigrate(&secrets.Secret{}))
+
+	var project models.Project
+	require.NoError(t, handler.db.First(&project, projectID).Error)
+	envConfig, err := json.Marshal(EnvironmentConfig{
+		Language: "node",
+		Version:  "20",
+		EnvVars: map[string]string{
+			"API_BASE":        "https://dev.example/api",
+			"PORT":            "9999",
+			"STRIPE_LIVE_KEY": "sk_live_x",
+			"SHARED":          "project",
+		},
+		PreviewExcludeEnv: []string{"admin_*"},
+	})
+	require.NoError(t, err)
+	project.Environment = map[string]interface{}{"config": string(envConfig)}
+	require.NoError(t, handler.db.Save(&project).Error)
+
+	manager, err := secrets.NewSecretsManager("preview-env-test-master-key-0123456789")
+	require.NoError(t, err)
+	handler.SetSecretsManager(manager)
+	for name, value := range map[string]string{"OPENAI_API_KEY": "sk-dev", "ADMIN_TOKEN": "root", "PROD_DATABASE_URL": "postgres://prod"} {
+		encrypted, salt, fingerprint, err := manager.Encrypt(project.OwnerID, value)
+		require.NoError(t, err)
+		require.NoError(t, handler.db.Create(&secrets.Secret{
+			UserID:         project.OwnerID,
+			ProjectID:      &project.ID,
+			Name:           name,
+			Type:           secrets.SecretTypeEnvironment,
+			EncryptedValue: encrypted,
+			Salt:           salt,
+			KeyFingerprint: fingerprint,
+		}).Error)
+	}
+
+	config := &preview.ServerConfig{
+		ProjectID:    projectID,
+		EntryFile:    "server.js",
+		Command:      "node server.js",
+		EnvVars:      map[string]string{"SHARED": "request"},
+		ReadyTimeout: 250 * time.Millisecond,
+	}
+	handler.injectProjectPreviewEnv(&project, config)
+
+	require.Equal(t, "https://dev.example/api", config.EnvVars["API_BASE"])
+	require.Equal(t, "sk-dev", config.EnvVars["OPENAI_API_KEY"])
+	require.Equal(t, "request", config.EnvVars["SHARED"])
+	for _, excluded := range []string{"PORT", "STRIPE_LIVE_KEY", "ADMIN_TOKEN", "PROD_DATABASE_URL"} {
+		require.NotContains(t, config.EnvVars, excluded)
+	}
+	require.Equal(t, []string{"API_BASE", "OPENAI_API_KEY"}, config.InjectedEnv)
+
+	readyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer readyServer.Close()
+	handler.serverRunner = preview.NewServerRunnerWithRuntime(handler.db, newFakePreviewRuntime("e2b", readyServer.URL))
+	proc, err := handler.serverRunner.Start(context.Background(), config)
+	require.NoError(t, err)
+	defer proc.Stop()
+
+	require.Equal(t, []string{"API_BASE", "OPENAI_API_KEY"}, handler.serverRunner.GetStatus(projectID).InjectedEnv)
+}
+
+func TestSetPreviewForwardedHeadersDescribesPublicOrigin(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodGet, "/api/v1/preview/proxy/7/login", nil)
+	context.Request.Host = "internal-preview:8080"
+	context.Request.RemoteAddr = "203.0.113.9:51234"
+	context.Request.Header.Set("X-Forwarded-Host", "preview.apex-build.dev")
+	context.Request.Header.Set("X-Forwarded-Proto", "https")
+
+	upstream := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:5173/login", nil)
+	setPreviewForwardedHeaders(upstream, context, "/api/v1/preview/proxy/7", false)
+
+	require.Equal(t, "https", upstream.Header.Get("X-Forwarded-Proto"))
+	require.Equal(t, "preview.apex-build.dev", upstream.Header.Get("X-Forwarded-Host"))
+	require.Equal(t, "/api/v1/preview/proxy/7", upstream.Header.Get("X-Forwarded-Prefix"))
+	require.Equal(t, `for="203.0.113.9";host="preview.apex-build.dev";proto=https`, upstream.Header.Get("Forwarded"))
+
+	setPreviewForwardedHeaders(upstream, context, "/api/v1/preview/proxy/7", true)
+	require.Empty(t, upstream.Header.Get("X-Forwarded-Prefix"))
+	require.Equal(t, "/api/v1/preview/proxy/7/login", previewUpstreamPath("/api/v1/preview/proxy/7", "/login", true))
+	require.Equal(t, "/", previewUpstreamPath("/api/v1/preview/proxy/7", "", false))
+}
+
+func TestRewritePreviewSetCookiesScopesCookiesToPreview(t *testing.T) {
+	prefix := "/api/v1/preview/proxy/7"
+	header := http.Header{}
+	header.Add("Set-Cookie", "session=abc; Path=/; Domain=localhost; Secure; HttpOnly; SameSite=None")
+	header.Add("Set-Cookie", "theme=dark; Path=/app")
+	header.Add("Set-Cookie", "scoped=1; Path="+prefix+"/admin")
+
+	rewritePreviewSetCookies(header, prefix, "http")
+
+	cookies := (&http.Response{Header: header}).Cookies()
+	require.Len(t, cookies, 3)
+	require.Equal(t, prefix, cookies[0].Path)
+	require.Empty(t, cookies[0].Domain)
+	require.False(t, cookies[0].Secure, "plain-HTTP previews would lose Secure cookies")
+	require.True(t, cookies[0].HttpOnly)
+	require.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	require.Equal(t, prefix+"/app", cookies[1].Path)
+	require.Equal(t, prefix+"/admin", cookies[2].Path)
+
+	secure := http.Header{}
+	secure.Add("Set-Cookie", "session=abc; Path=/; Secure; SameSite=None")
+	rewritePreviewSetCookies(secure, prefix, "https")
+	cookie := (&http.Response{Header: secure}).Cookies()[0]
+	require.True(t, cookie.Secure)
+	require.Equal(t, http.SameSiteNoneMode, cookie.SameSite)
+}
+
+func TestRewritePreviewSourceMapsForProxy(t *testing.T) {
+	prefix := "/api/v1/preview/proxy/7"
+	js := "console.log(1);\n//# sourceMappingURL=/assets/index.js.map\n"
+	require.Equal(t, "console.log(1);\n//# sourceMappingURL="+prefix+"/assets/index.js.map?preview_token=tok\n",
+		rewritePreviewSourceMapComments(js, prefix, "tok"))
+
+	for _, unchanged := range []string{
+		"//# sourceMappingURL=index.js.map",
+		"//# sourceMappingURL=data:application/json;base64,e30=",
+		"//# sourceMappingURL=//cdn.example.com/index.js.map",
+		"//# sourceMappingURL=" + prefix + "/index.js.map",
+	} {
+		require.Equal(t, unchanged, rewritePreviewSourceMapComments(unchanged, prefix, ""))
+	}
+
+	header := http.Header{}
+	header.Set("SourceMap", "/assets/app.js.map")
+	header.Set("X-SourceMap", "app.js.map")
+	rewritePreviewSourceMapHeaders(header, prefix, "")
+	require.Equal(t, prefix+"/assets/app.js.map", header.Get("SourceMap"))
+	require.Equal(t, "app.js.map", header.Get("X-SourceMap"))
+}
+
+func TestDiagnosticErrorFramesUseOriginalPositions(t *testing.T) {
+	frames := diagnosticErrorFrames([]preview.DiagnosticFrame{
+		{Function: "boom", File: "/__apex_bundle.js", Line: 2, Column: 7, Original: &preview.OriginalPosition{Source: "src/App.tsx", Line: 12, Column: 5}},
+		{Function: "render", File: "/__apex_bundle.js", Line: 1, Column: 900},
+		{Function: "main", File: "/src/main.tsx", Line: 4, Column: 1},
+	})
+	require.Len(t, frames, 3)
+	require.Equal(t, hosting.ErrorFrame{Filename: "src/main.tsx", Function: "main", Line: 4, Column: 1, InApp: true}, frames[0], "oldest call first")
+	require.False(t, frames[1].InApp, "unmapped bundle frames are not project code")
+	require.Equal(t, hosting.ErrorFrame{Filename: "src/App.tsx", Function: "boom", Line: 12, Column: 5, InApp: true}, frames[2])
+}
+
+func TestPreviewHandlerGetDevCertificate(t *testing.T) {
+	handler, _ := newPreviewHandlerTestFixture(t, false)
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodGet, "/api/v1/preview/dev-cert", nil)
+	handler.GetDevCertificate(context)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	handler.SetDevCertificate([]byte("-----BEGIN CERTIFICATE-----\n"))
+	recorder = httptest.NewRecorder()
+	context, _ = gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodGet, "/api/v1/preview/dev-cert", nil)
+	handler.GetDevCertificate(context)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Content-Disposition"), "apex-preview-dev-ca.pem")
+}
+
+func TestPreviewHMRBridgeInjectedAndSocketsTracked(t *testing.T) {
+	handler, _ := newPreviewHandlerTestFixture(t, false)
+
+	html := `<html><head><meta charset="utf-8"></head><body><header>Nav</header><script type="module" src="/@vite/client"></script></body></html>`
+	prefix := "/api/v1/preview/backend-proxy/41"
+	rewritten := handler.rewritePreviewHTMLForProxyWithPrefix(html, prefix, "", "hmr-token")
+
+	bridgeAt := strings.Index(rewritten, "__APEX_HMR_BRIDGE__")
+	require.Greater(t, bridgeAt, strings.Index(rewritten, "<head>"))
+	require.Less(t, bridgeAt, strings.Index(rewritten, `<meta charset="utf-8">`))
+	require.Contains(t, rewritten, `var _px="`+prefix+`";`)
+	require.Contains(t, rewritten, `var _pt="hmr-token";`)
+	require.Equal(t, `<script>s</script><header id="x">`, injectPreviewHeadScript(`<header id="x">`, `<script>s</script>`))
+	require.Equal(t, `<BODY class="a"><script>s</script><header>`, injectPreviewHeadScript(`<BODY class="a"><header>`, `<script>s</script>`))
+
+	vite := httptest.NewRequest(http.MethodGet, "/", nil)
+	vite.Header.Set("Sec-WebSocket-Protocol", "vite-hmr")
+	next := httptest.NewRequest(http.MethodGet, "/_next/webpack-hmr", nil)
+	app := httptest.NewRequest(http.MethodGet, "/socket", nil)
+	require.True(t, isPreviewHMRUpgrade(vite, "/"))
+	require.True(t, isPreviewHMRUpgrade(next, "/_next/webpack-hmr"))
+	require.False(t, isPreviewHMRUpgrade(app, "/socket"))
+
+	require.False(t, previewHMRConnected(41))
+	releaseApp := trackPreviewHMR(app, "/socket", 41)
+	require.False(t, previewHMRConnected(41))
+	release := trackPreviewHMR(vite, "/", 41)
+	require.True(t, previewHMRConnected(41))
+	release()
+	releaseApp()
+	require.False(t, previewHMRConnected(41))
+
+	upgrade := httptest.NewRequest(http.MethodGet, "/?token=a&preview_token=b&v=1", nil)
+	stripPreviewTokenQuery(upgrade)
+	require.Equal(t, "v=1", upgrade.URL.RawQuery)
+}
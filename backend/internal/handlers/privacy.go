@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/privacy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyHandler serves GDPR/CCPA data export and account deletion.
+type PrivacyHandler struct {
+	service *privacy.Service
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler.
+func NewPrivacyHandler(service *privacy.Service) *PrivacyHandler {
+	return &PrivacyHandler{service: service}
+}
+
+// ExportAccount streams a zip archive of all of the user's data.
+// GET /account/export
+func (h *PrivacyHandler) ExportAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	filename := fmt.Sprintf("apex-export-%d-%s.zip", userID, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+
+	if err := h.service.Export(c.Request.Context(), userID, c.Writer); err != nil {
+		log.Printf("privacy: export for user %d failed: %v", userID, err)
+		if c.Writer.Written() {
+			// Headers are gone; abort so the client sees a truncated download.
+			c.Abort()
+			return
+		}
+		c.Header("Content-Disposition", "")
+		if errors.Is(err, privacy.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Data export failed"})
+	}
+}
+
+// RequestDeletion starts account deletion. The current password is required
+// unless the account has none (SSO and SCIM users), and a confirmation code
+// is emailed to the account address.
+// POST /account/deletion
+func (h *PrivacyHandler) RequestDeletion(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	deletion, err := h.service.RequestDeletion(c.Request.Context(), userID, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, privacy.ErrInvalidPassword):
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, privacy.ErrReauthUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, privacy.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to start account deletion"})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": deletion})
+}
+
+// ConfirmDeletion permanently deletes the account once the emailed code is
+// verified.
+// POST /account/deletion/confirm
+func (h *PrivacyHandler) ConfirmDeletion(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "code is required"})
+		return
+	}
+
+	report, err := h.service.ConfirmDeletion(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, privacy.ErrNoPendingDeletion):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, privacy.ErrInvalidCode):
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, privacy.ErrDeletionExpired), errors.Is(err, privacy.ErrTooManyAttempts):
+			c.JSON(http.StatusGone, gin.H{"success": false, "error": err.Error()})
+		default:
+			log.Printf("privacy: deletion for user %d failed: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Account deletion failed; nothing was removed from billing"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// CancelDeletion withdraws a pending deletion request.
+// DELETE /account/deletion
+func (h *PrivacyHandler) CancelDeletion(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	if err := h.service.CancelDeletion(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, privacy.ErrNoPendingDeletion) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to cancel account deletion"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RequestProjectExport queues a backup of all of the user's projects. One
+// export is allowed per day.
+// POST /account/project-exports
+func (h *PrivacyHandler) RequestProjectExport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	export, err := h.service.RequestProjectExport(c.Request.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, privacy.ErrExportRateLimited):
+			retryAt := export.CreatedAt.Add(privacy.ProjectExportInterval)
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(retryAt).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "error": err.Error(), "data": export, "retry_at": retryAt})
+		case errors.Is(err, privacy.ErrExportUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, privacy.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "User not found"})
+		default:
+			log.Printf("privacy: project export request for user %d failed: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to start project export"})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": export})
+}
+
+// ListProjectExports returns the user's recent project exports.
+// GET /account/project-exports
+func (h *PrivacyHandler) ListProjectExports(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	exports, err := h.service.ListProjectExports(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to list project exports"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": exports})
+}
+
+// DownloadProjectExport streams a completed project export.
+// GET /account/project-exports/:exportId/download
+func (h *PrivacyHandler) DownloadProjectExport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	exportID, err := strconv.ParseUint(c.Param("exportId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid export ID"})
+		return
+	}
+
+	export, reader, size, err := h.service.OpenProjectExport(c.Request.Context(), userID, uint(exportID))
+	if err != nil {
+		switch {
+		case errors.Is(err, privacy.ErrExportNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, privacy.ErrExportNotReady):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error(), "data": export})
+		default:
+			log.Printf("privacy: project export %d download for user %d failed: %v", exportID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to read project export"})
+		}
+		return
+	}
+	defer reader.Close()
+
+	filename := fmt.Sprintf("apex-projects-%d-%s.zip", userID, export.CreatedAt.UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, size, "application/zip", reader, nil)
+}
+
+// RegisterRoutes registers the account privacy endpoints.
+func (h *PrivacyHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	account := rg.Group("/account")
+	account.GET("/export", h.ExportAccount)
+	account.POST("/project-exports", h.RequestProjectExport)
+	account.GET("/project-exports", h.ListProjectExports)
+	account.GET("/project-exports/:exportId/download", h.DownloadProjectExport)
+	account.POST("/deletion", h.RequestDeletion)
+	account.POST("/deletion/confirm", h.ConfirmDeletion)
+	account.DELETE("/deletion", h.CancelDeletion)
+}
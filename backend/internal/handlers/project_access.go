@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/collaboration"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// accessibleProject loads the project in the path and checks the caller may
+// use it. access resolves owner, collaborator and organization roles;
+// without it only the owner of a personal project gets in. When allowed is
+// set it must also accept the caller's permission.
+func accessibleProject(c *gin.Context, db *gorm.DB, access collaboration.AccessResolver, allowed func(collaboration.PermissionLevel) bool) (*models.Project, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project ID"})
+		return nil, false
+	}
+	return accessibleProjectByID(c, db, access, uint(projectID), allowed)
+}
+
+// accessibleProjectByID is accessibleProject for a project ID taken from
+// somewhere other than the :id path parameter
+func accessibleProjectByID(c *gin.Context, db *gorm.DB, access collaboration.AccessResolver, projectID uint, allowed func(collaboration.PermissionLevel) bool) (*models.Project, bool) {
+	userID := c.GetUint("user_id")
+	var project models.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Project not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load project"})
+		}
+		return nil, false
+	}
+
+	var permission collaboration.PermissionLevel
+	if access != nil {
+		resolved, err := access(userID, project.ID)
+		switch {
+		case errors.Is(err, collaboration.ErrProjectAccessDenied):
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+			return nil, false
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check project access"})
+			return nil, false
+		}
+		permission = resolved.Permission
+	} else if project.OrganizationID == nil && project.OwnerID == userID {
+		permission = collaboration.PermissionOwner
+	} else {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return nil, false
+	}
+
+	if allowed != nil && !allowed(permission) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return nil, false
+	}
+	return &project, true
+}
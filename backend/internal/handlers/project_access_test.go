@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/internal/collaboration"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAccessibleProjectUsesResolvedPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Project{}))
+	orgID := uint(9)
+	personal := models.Project{Name: "personal", OwnerID: 1, Language: "go"}
+	orgOwned := models.Project{Name: "org", OwnerID: 1, Language: "go", OrganizationID: &orgID}
+	require.NoError(t, db.Create(&personal).Error)
+	require.NoError(t, db.Create(&orgOwned).Error)
+
+	check := func(access collaboration.AccessResolver, userID uint, project models.Project, allowed func(collaboration.PermissionLevel) bool) int {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+		c.Set("user_id", userID)
+		if _, ok := accessibleProject(c, db, access, allowed); ok {
+			return http.StatusOK
+		}
+		return recorder.Code
+	}
+
+	// Without a resolver only the owner of a personal project gets in
+	require.Equal(t, http.StatusOK, check(nil, 1, personal, canAdminProject))
+	require.Equal(t, http.StatusForbidden, check(nil, 2, personal, nil))
+	require.Equal(t, http.StatusForbidden, check(nil, 1, orgOwned, nil))
+
+	// With one, collaborators and organization members get their role
+	roles := map[uint]collaboration.PermissionLevel{2: collaboration.PermissionEditor, 3: collaboration.PermissionViewer}
+	resolver := func(userID, projectID uint) (*collaboration.ProjectAccess, error) {
+		permission, ok := roles[userID]
+		if !ok {
+			return nil, collaboration.ErrProjectAccessDenied
+		}
+		return &collaboration.ProjectAccess{ProjectID: projectID, Permission: permission}, nil
+	}
+	require.Equal(t, http.StatusOK, check(resolver, 2, orgOwned, canEditProject))
+	require.Equal(t, http.StatusForbidden, check(resolver, 2, orgOwned, canAdminProject))
+	require.Equal(t, http.StatusOK, check(resolver, 3, orgOwned, nil))
+	require.Equal(t, http.StatusForbidden, check(resolver, 3, orgOwned, canEditProject))
+	require.Equal(t, http.StatusForbidden, check(resolver, 4, orgOwned, nil))
+	require.Equal(t, http.StatusNotFound, check(resolver, 2, models.Project{ID: 999}, nil))
+}
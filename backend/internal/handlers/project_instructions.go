@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/instructions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectInstructionsHandler serves a project's apex.md AI instructions.
+type ProjectInstructionsHandler struct {
+	service *instructions.Service
+}
+
+// NewProjectInstructionsHandler creates a new ProjectInstructionsHandler.
+func NewProjectInstructionsHandler(service *instructions.Service) *ProjectInstructionsHandler {
+	return &ProjectInstructionsHandler{service: service}
+}
+
+// GetInstructions returns the project's apex.md, its organization's
+// instructions, and the block that AI prompts on the project receive.
+// GET /projects/:id/instructions
+func (h *ProjectInstructionsHandler) GetInstructions(c *gin.Context) {
+	userID, projectID, ok := h.parseRequest(c)
+	if !ok {
+		return
+	}
+	resolved, err := h.service.Load(c.Request.Context(), userID, projectID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, resolved)
+}
+
+// UpdateInstructions replaces the project's apex.md. Empty content removes it.
+// PUT /projects/:id/instructions
+func (h *ProjectInstructionsHandler) UpdateInstructions(c *gin.Context) {
+	userID, projectID, ok := h.parseRequest(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	resolved, err := h.service.SaveProjectInstructions(c.Request.Context(), userID, projectID, req.Content)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, resolved)
+}
+
+func (h *ProjectInstructionsHandler) respond(c *gin.Context, resolved *instructions.Instructions) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"instructions":           resolved,
+			"effective_prompt":       resolved.PromptBlock(),
+			"max_project_bytes":      instructions.MaxProjectBytes,
+			"max_organization_bytes": instructions.MaxOrganizationBytes,
+		},
+	})
+}
+
+func (h *ProjectInstructionsHandler) parseRequest(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project ID"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func (h *ProjectInstructionsHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, instructions.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, instructions.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, instructions.ErrProjectInstructionsDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load project instructions"})
+	}
+}
+
+// RegisterRoutes registers the project instructions endpoints.
+func (h *ProjectInstructionsHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/instructions", h.GetInstructions)
+	rg.PUT("/projects/:id/instructions", h.UpdateInstructions)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/ownership"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectOwnershipHandler serves organization-owned projects and project
+// ownership transfers.
+type ProjectOwnershipHandler struct {
+	service    *ownership.Service
+	invalidate ProjectCacheInvalidator
+}
+
+// NewProjectOwnershipHandler creates a new ProjectOwnershipHandler.
+func NewProjectOwnershipHandler(service *ownership.Service) *ProjectOwnershipHandler {
+	return &ProjectOwnershipHandler{service: service}
+}
+
+// SetProjectCacheInvalidator wires the project cache so listings reflect a
+// new owner immediately.
+func (h *ProjectOwnershipHandler) SetProjectCacheInvalidator(inv ProjectCacheInvalidator) {
+	h.invalidate = inv
+}
+
+// CreateOrgProject creates a project owned by an organization.
+// POST /enterprise/organizations/:id/projects
+func (h *ProjectOwnershipHandler) CreateOrgProject(c *gin.Context) {
+	userID, orgID, ok := h.userAndParam(c, "Invalid organization ID")
+	if !ok {
+		return
+	}
+	var req struct {
+		Name        string `json:"name" binding:"required,min=1,max=100"`
+		Description string `json:"description" binding:"max=500"`
+		Language    string `json:"language"`
+		Framework   string `json:"framework"`
+		IsPublic    bool   `json:"is_public"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format: " + err.Error()})
+		return
+	}
+	if req.Language == "" {
+		req.Language = "typescript"
+	}
+
+	project := &models.Project{
+		Name:        req.Name,
+		Description: req.Description,
+		Language:    req.Language,
+		Framework:   req.Framework,
+		IsPublic:    req.IsPublic,
+	}
+	if err := h.service.CreateOrgProject(c.Request.Context(), userID, orgID, project); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.invalidateProject(c.Request.Context(), userID, project.ID)
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": project})
+}
+
+// ListOrgProjects lists an organization's projects.
+// GET /enterprise/organizations/:id/projects
+func (h *ProjectOwnershipHandler) ListOrgProjects(c *gin.Context) {
+	userID, orgID, ok := h.userAndParam(c, "Invalid organization ID")
+	if !ok {
+		return
+	}
+	projects, err := h.service.ListOrgProjects(c.Request.Context(), userID, orgID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"projects": projects}})
+}
+
+// InitiateTransfer offers a project to a user or organization.
+// POST /projects/:id/transfers
+func (h *ProjectOwnershipHandler) InitiateTransfer(c *gin.Context) {
+	userID, projectID, ok := h.userAndParam(c, "Invalid project ID")
+	if !ok {
+		return
+	}
+	var target ownership.Target
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format: " + err.Error()})
+		return
+	}
+	transfer, err := h.service.InitiateTransfer(c.Request.Context(), userID, projectID, target)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": transfer})
+}
+
+// ListTransfers lists pending transfers the user sent or can respond to.
+// GET /project-transfers
+func (h *ProjectOwnershipHandler) ListTransfers(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	transfers, err := h.service.ListTransfers(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"transfers": transfers}})
+}
+
+// AcceptTransfer confirms a transfer and moves the project.
+// POST /project-transfers/:id/accept
+func (h *ProjectOwnershipHandler) AcceptTransfer(c *gin.Context) {
+	h.respond(c, h.service.AcceptTransfer, true)
+}
+
+// DeclineTransfer rejects a transfer.
+// POST /project-transfers/:id/decline
+func (h *ProjectOwnershipHandler) DeclineTransfer(c *gin.Context) {
+	h.respond(c, h.service.DeclineTransfer, false)
+}
+
+// CancelTransfer withdraws a transfer the user initiated.
+// DELETE /project-transfers/:id
+func (h *ProjectOwnershipHandler) CancelTransfer(c *gin.Context) {
+	h.respond(c, h.service.CancelTransfer, false)
+}
+
+func (h *ProjectOwnershipHandler) respond(c *gin.Context, op func(ctx context.Context, userID, transferID uint) (*ownership.ProjectTransfer, error), moved bool) {
+	userID, transferID, ok := h.userAndParam(c, "Invalid transfer ID")
+	if !ok {
+		return
+	}
+	transfer, err := op(c.Request.Context(), userID, transferID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	if moved {
+		h.invalidateProject(c.Request.Context(), userID, transfer.ProjectID)
+		if transfer.FromUserID != nil {
+			h.invalidateProject(c.Request.Context(), *transfer.FromUserID, transfer.ProjectID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": transfer})
+}
+
+func (h *ProjectOwnershipHandler) userAndParam(c *gin.Context, invalidMsg string) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": invalidMsg})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func (h *ProjectOwnershipHandler) invalidateProject(ctx context.Context, userID, projectID uint) {
+	if h.invalidate != nil {
+		h.invalidate.InvalidateProjectCache(ctx, userID, projectID)
+	}
+}
+
+func (h *ProjectOwnershipHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ownership.ErrProjectNotFound), errors.Is(err, ownership.ErrOrgNotFound),
+		errors.Is(err, ownership.ErrUserNotFound), errors.Is(err, ownership.ErrTransferNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ownership.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ownership.ErrInvalidTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ownership.ErrTransferClosed):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, ownership.ErrOrgProjectQuota), errors.Is(err, ownership.ErrUserProjectQuota):
+		c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "error": err.Error(), "code": "QUOTA_EXCEEDED"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Project ownership operation failed"})
+	}
+}
+
+// RegisterRoutes registers organization project and transfer endpoints.
+func (h *ProjectOwnershipHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/enterprise/organizations/:id/projects", h.ListOrgProjects)
+	rg.POST("/enterprise/organizations/:id/projects", h.CreateOrgProject)
+	rg.POST("/projects/:id/transfers", h.InitiateTransfer)
+	rg.GET("/project-transfers", h.ListTransfers)
+	rg.POST("/project-transfers/:id/accept", h.AcceptTransfer)
+	rg.POST("/project-transfers/:id/decline", h.DeclineTransfer)
+	rg.DELETE("/project-transfers/:id", h.CancelTransfer)
+}
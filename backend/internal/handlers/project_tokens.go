@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/schedules"
+	"apex-build/internal/secrets"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxProjectOutputBytes caps a file written through the project API
+const maxProjectOutputBytes = 1024 * 1024
+
+// projectOutputsDir is the only directory project tokens may write to, so a
+// leaked token cannot rewrite the project's source
+const projectOutputsDir = "outputs/"
+
+// SetProjectTokens gives scheduled runs their project's service token
+func (h *ExecutionHandler) SetProjectTokens(tokens *secrets.ProjectTokens) {
+	h.ProjectTokens = tokens
+}
+
+// projectTokenEnv returns the project's service token variables, or nil
+// when it has no token
+func (h *ExecutionHandler) projectTokenEnv(projectID uint) map[string]string {
+	if h.ProjectTokens == nil {
+		return nil
+	}
+	env, err := h.ProjectTokens.Env(projectID)
+	if err != nil {
+		return nil
+	}
+	return env
+}
+
+// ProjectTokenHandler lets owners manage their projects' service tokens and
+// serves the project API those tokens call
+type ProjectTokenHandler struct {
+	db      *gorm.DB
+	tokens  *secrets.ProjectTokens
+	access  collaboration.AccessResolver
+	storage ProjectStorageUsage
+}
+
+// ProjectStorageUsage checks and records project writes made without a
+// signed-in user, against whoever pays for the project's storage.
+// Implemented by usage.Tracker.
+type ProjectStorageUsage interface {
+	CheckProjectStorageQuota(ctx context.Context, userID uint, plan usage.PlanType, projectID uint, additional int64) (allowed bool, current int64, limit int64, orgID *uint, err error)
+	RecordStorageChange(ctx context.Context, userID uint, projectID *uint, bytesChange int64) error
+}
+
+// NewProjectTokenHandler creates a new ProjectTokenHandler
+func NewProjectTokenHandler(db *gorm.DB, tokens *secrets.ProjectTokens) *ProjectTokenHandler {
+	return &ProjectTokenHandler{db: db, tokens: tokens}
+}
+
+// SetAccessResolver lets collaborators and organization members in,
+// according to their permission on the project
+func (h *ProjectTokenHandler) SetAccessResolver(access collaboration.AccessResolver) {
+	h.access = access
+}
+
+// SetStorageUsage applies the project's storage quota to output writes and
+// records them in its storage usage
+func (h *ProjectTokenHandler) SetStorageUsage(storage ProjectStorageUsage) {
+	h.storage = storage
+}
+
+// RegisterRoutes registers the service token routes on the protected group
+func (h *ProjectTokenHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/service-token", h.GetServiceToken)
+	rg.POST("/projects/:id/service-token", h.EnableServiceToken)
+	rg.DELETE("/projects/:id/service-token", h.DisableServiceToken)
+	rg.POST("/projects/:id/service-token/rotate", h.RotateServiceToken)
+	rg.GET("/projects/:id/service-token/usage", h.GetServiceTokenUsage)
+}
+
+// RegisterAPIRoutes registers the token-authenticated project API. Tokens
+// address only their own project, so the routes take no project ID.
+func (h *ProjectTokenHandler) RegisterAPIRoutes(rg *gin.RouterGroup) {
+	api := rg.Group("/project-api")
+	api.Use(h.Middleware())
+	api.GET("/project", h.GetTokenProject)
+	api.GET("/files", h.ListTokenProjectFiles)
+	api.GET("/files/*path", h.ReadTokenProjectFile)
+	api.PUT("/outputs/*path", h.WriteTokenProjectOutput)
+}
+
+// serviceTokenResponse describes a token without its value, which only the
+// project's jobs and apps receive
+func serviceTokenResponse(token *secrets.ProjectToken) gin.H {
+	return gin.H{
+		"success": true,
+		"enabled": true,
+		"token":   token,
+		"scopes":  secrets.ProjectTokenScopes,
+		"env_vars": []string{
+			secrets.ProjectTokenEnvVar,
+			secrets.ProjectIDEnvVar,
+			secrets.ProjectAPIURLEnvVar,
+		},
+	}
+}
+
+// GetServiceToken reports whether the project has a service token
+func (h *ProjectTokenHandler) GetServiceToken(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canAdminProject)
+	if !ok {
+		return
+	}
+	token, err := h.tokens.Get(project.ID)
+	if errors.Is(err, secrets.ErrProjectTokenNotFound) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "enabled": false, "scopes": secrets.ProjectTokenScopes})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load service token"})
+		return
+	}
+	c.JSON(http.StatusOK, serviceTokenResponse(token))
+}
+
+// EnableServiceToken gives the project a service token. Its scheduled runs
+// and deployments started afterwards receive it.
+func (h *ProjectTokenHandler) EnableServiceToken(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canAdminProject)
+	if !ok {
+		return
+	}
+	if rejectArchivedProject(c, project) {
+		return
+	}
+	token, err := h.tokens.Enable(project.ID, project.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to enable service token"})
+		return
+	}
+	c.JSON(http.StatusOK, serviceTokenResponse(token))
+}
+
+// DisableServiceToken revokes the project's service token at once
+func (h *ProjectTokenHandler) DisableServiceToken(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canAdminProject)
+	if !ok {
+		return
+	}
+	if err := h.tokens.Disable(project.ID); err != nil {
+		if errors.Is(err, secrets.ErrProjectTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to disable service token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "enabled": false})
+}
+
+// RotateServiceToken rotates the project's service token now, for example
+// after it leaked
+func (h *ProjectTokenHandler) RotateServiceToken(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canAdminProject)
+	if !ok {
+		return
+	}
+	token, err := h.tokens.Rotate(project.ID)
+	if err != nil {
+		if errors.Is(err, secrets.ErrProjectTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to rotate service token"})
+		return
+	}
+	c.JSON(http.StatusOK, serviceTokenResponse(token))
+}
+
+// GetServiceTokenUsage lists recent requests made with the project's token
+func (h *ProjectTokenHandler) GetServiceTokenUsage(c *gin.Context) {
+	project, ok := accessibleProject(c, h.db, h.access, canAdminProject)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	usage, err := h.tokens.Usage(project.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load service token usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "usage": usage})
+}
+
+// Middleware authenticates project API requests with a bearer project
+// token and records each request in the token's usage log
+func (h *ProjectTokenHandler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		plaintext := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if header == "" || plaintext == header {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "A project token is required (Authorization: Bearer " + secrets.ProjectTokenPrefix + "...)",
+				"code":  "AUTH_REQUIRED",
+			})
+			return
+		}
+		token, version, err := h.tokens.Authenticate(plaintext)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+				"code":  "INVALID_TOKEN",
+			})
+			return
+		}
+		c.Set("project_token", token)
+		c.Next()
+
+		h.tokens.RecordUsage(&secrets.ProjectTokenUsage{
+			TokenID:   token.ID,
+			ProjectID: token.ProjectID,
+			Version:   version,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			IPAddress: c.ClientIP(),
+		})
+	}
+}
+
+// tokenProject loads the project of the authenticated token
+func (h *ProjectTokenHandler) tokenProject(c *gin.Context) (*models.Project, bool) {
+	token := c.MustGet("project_token").(*secrets.ProjectToken)
+	var project models.Project
+	if err := h.db.First(&project, token.ProjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Project not found"})
+		return nil, false
+	}
+	return &project, true
+}
+
+// GetTokenProject handles GET /project-api/project
+func (h *ProjectTokenHandler) GetTokenProject(c *gin.Context) {
+	project, ok := h.tokenProject(c)
+	if !ok {
+		return
+	}
+	token := c.MustGet("project_token").(*secrets.ProjectToken)
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"project_id":    project.ID,
+		"name":          project.Name,
+		"language":      project.Language,
+		"scopes":        secrets.ProjectTokenScopes,
+		"outputs_dir":   projectOutputsDir,
+		"token_version": token.Version,
+	})
+}
+
+// ListTokenProjectFiles handles GET /project-api/files
+func (h *ProjectTokenHandler) ListTokenProjectFiles(c *gin.Context) {
+	project, ok := h.tokenProject(c)
+	if !ok {
+		return
+	}
+	var files []struct {
+		Path      string    `json:"path"`
+		Size      int64     `json:"size"`
+		IsBinary  bool      `json:"is_binary"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if err := h.db.Model(&models.File{}).
+		Select("path", "size", "is_binary", "updated_at").
+		Where("project_id = ? AND type = ?", project.ID, "file").
+		Order("path ASC").
+		Scan(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to list files"})
+		return
+	}
+	for i := range files {
+		files[i].Path = strings.TrimPrefix(files[i].Path, "/")
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "files": files})
+}
+
+// ReadTokenProjectFile handles GET /project-api/files/*path. Binary files
+// are listed with empty content.
+func (h *ProjectTokenHandler) ReadTokenProjectFile(c *gin.Context) {
+	project, ok := h.tokenProject(c)
+	if !ok {
+		return
+	}
+	filePath, err := normalizeProjectFilePath(c.Param("path"))
+	if err != nil || filePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid file path"})
+		return
+	}
+	var file models.File
+	if err := h.db.Where("project_id = ? AND type = ? AND path IN ?", project.ID, "file", []string{filePath, "/" + filePath}).
+		First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "File not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"path":       filePath,
+		"content":    file.Content,
+		"size":       file.Size,
+		"is_binary":  file.IsBinary,
+		"version":    file.Version,
+		"updated_at": file.UpdatedAt,
+	})
+}
+
+// WriteTokenProjectOutput handles PUT /project-api/outputs/*path. It
+// creates or replaces a text file under outputs/.
+func (h *ProjectTokenHandler) WriteTokenProjectOutput(c *gin.Context) {
+	project, ok := h.tokenProject(c)
+	if !ok {
+		return
+	}
+	if rejectArchivedProject(c, project) {
+		return
+	}
+	relative, err := normalizeProjectFilePath(c.Param("path"))
+	if err != nil || relative == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid file path"})
+		return
+	}
+	var req struct {
+		Content string `json:"content"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxProjectOutputBytes*2)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	if len(req.Content) > maxProjectOutputBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": "Output files are limited to 1 MB"})
+		return
+	}
+
+	filePath := projectOutputsDir + relative
+	paths := []string{filePath, "/" + filePath}
+	size := int64(len(req.Content))
+	var existing models.File
+	err = h.db.Select("size").Where("project_id = ? AND path IN ?", project.ID, paths).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to write output file"})
+		return
+	}
+	delta := size - existing.Size
+	if !h.allowStorageDelta(c, project, delta) {
+		return
+	}
+	sum := sha256.Sum256([]byte(req.Content))
+	hash := hex.EncodeToString(sum[:])
+
+	var file models.File
+	created := false
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("project_id = ? AND path IN ?", project.ID, paths).First(&file).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			created = true
+			file = models.File{
+				ProjectID:  project.ID,
+				Path:       filePath,
+				Name:       path.Base(filePath),
+				Type:       "file",
+				MimeType:   "text/plain",
+				Content:    req.Content,
+				Size:       size,
+				Hash:       hash,
+				Version:    1,
+				LastEditBy: project.OwnerID,
+			}
+			return tx.Create(&file).Error
+		case err != nil:
+			return err
+		}
+		if file.Content != req.Content {
+			// Versioned with the new content, as editor saves are
+			previous := file.Content
+			file.Content, file.Size = req.Content, size
+			var owner models.User
+			tx.Select("username").First(&owner, project.OwnerID)
+			CreateFileVersion(tx, &file, project.OwnerID, owner.Username, "edit", "Written by the project's service token")
+			file.Content = previous
+		}
+		file.Version++
+		return tx.Model(&file).Updates(map[string]interface{}{
+			"content":      req.Content,
+			"size":         size,
+			"hash":         hash,
+			"is_binary":    false,
+			"version":      file.Version,
+			"last_edit_by": project.OwnerID,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to write output file"})
+		return
+	}
+	if h.storage != nil && delta != 0 {
+		if err := h.storage.RecordStorageChange(c.Request.Context(), project.OwnerID, &project.ID, delta); err != nil {
+			log.Printf("usage tracker: failed to record storage change for user %d: %v", project.OwnerID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"path":    filePath,
+		"size":    len(req.Content),
+		"version": file.Version,
+		"created": created,
+	})
+}
+
+// allowStorageDelta checks an output write growing the project by delta
+// against the storage quota of whoever pays for the project, writing 413
+// when it would go over
+func (h *ProjectTokenHandler) allowStorageDelta(c *gin.Context, project *models.Project, delta int64) bool {
+	if h.storage == nil || delta <= 0 {
+		return true
+	}
+	plan, enforce := schedules.BillingFor(c.Request.Context(), h.db, project.OwnerID)
+	if !enforce {
+		return true
+	}
+	allowed, current, limit, _, err := h.storage.CheckProjectStorageQuota(c.Request.Context(), project.OwnerID, plan, project.ID, delta)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Storage quota is temporarily unavailable"})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success":   false,
+			"error":     "The project's storage limit has been reached",
+			"code":      "STORAGE_QUOTA_EXCEEDED",
+			"current":   current,
+			"limit":     limit,
+			"requested": delta,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-build/internal/secrets"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestProjectAPIIsScopedToTheTokensProject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Project{}, &models.File{}))
+	key, err := secrets.GenerateMasterKey()
+	require.NoError(t, err)
+	sm, err := secrets.NewSecretsManager(key)
+	require.NoError(t, err)
+	tokens := secrets.NewProjectTokens(db, sm, "")
+	require.NoError(t, tokens.AutoMigrate())
+
+	own := models.Project{Name: "poller", OwnerID: 3, Language: "python"}
+	other := models.Project{Name: "other", OwnerID: 3, Language: "python"}
+	require.NoError(t, db.Create(&own).Error)
+	require.NoError(t, db.Create(&other).Error)
+	require.NoError(t, db.Create(&models.File{ProjectID: own.ID, Path: "/main.py", Name: "main.py", Type: "file", Content: "print(1)"}).Error)
+	require.NoError(t, db.Create(&models.File{ProjectID: other.ID, Path: "/secret.py", Name: "secret.py", Type: "file", Content: "KEY=1"}).Error)
+
+	_, err = tokens.Enable(own.ID, own.OwnerID)
+	require.NoError(t, err)
+	env, err := tokens.Env(own.ID)
+	require.NoError(t, err)
+	token := env[secrets.ProjectTokenEnvVar]
+
+	router := gin.New()
+	NewProjectTokenHandler(db, tokens).RegisterAPIRoutes(router.Group("/api/v1"))
+	do := func(method, path, bearer, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/project-api/files", "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/project-api/files", "apx_proj_1_nope", "").Code)
+
+	w := do(http.MethodGet, "/api/v1/project-api/files/main.py", token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "print(1)")
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/project-api/files/secret.py", token, "").Code)
+
+	// Writes land under outputs/ only
+	w = do(http.MethodPut, "/api/v1/project-api/outputs/prices.json", token, `{"content":"{\"btc\":1}"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var written struct {
+		Path    string `json:"path"`
+		Created bool   `json:"created"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &written))
+	require.Equal(t, "outputs/prices.json", written.Path)
+	require.True(t, written.Created)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/v1/project-api/outputs/../main.py", token, `{"content":"x"}`).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPut, "/api/v1/project-api/files/main.py", token, `{"content":"x"}`).Code)
+
+	var main models.File
+	require.NoError(t, db.Where("project_id = ? AND path = ?", own.ID, "/main.py").First(&main).Error)
+	require.Equal(t, "print(1)", main.Content)
+
+	usage, err := tokens.Usage(own.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, usage, 4, "unmatched routes are not logged")
+	require.Equal(t, http.MethodPut, usage[0].Method)
+}
+
+// fakeProjectStorage allows writes while the project stays within limit
+// bytes and keeps the recorded changes
+type fakeProjectStorage struct {
+	used     int64
+	limit    int64
+	recorded []int64
+}
+
+func (f *fakeProjectStorage) CheckProjectStorageQuota(ctx context.Context, userID uint, plan usage.PlanType, projectID uint, additional int64) (bool, int64, int64, *uint, error) {
+	return f.used+additional <= f.limit, f.used, f.limit, nil, nil
+}
+
+func (f *fakeProjectStorage) RecordStorageChange(ctx context.Context, userID uint, projectID *uint, bytesChange int64) error {
+	f.used += bytesChange
+	f.recorded = append(f.recorded, bytesChange)
+	return nil
+}
+
+func TestProjectAPIOutputWritesCountAgainstStorageQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}, &models.FileVersion{}))
+	key, err := secrets.GenerateMasterKey()
+	require.NoError(t, err)
+	sm, err := secrets.NewSecretsManager(key)
+	require.NoError(t, err)
+	tokens := secrets.NewProjectTokens(db, sm, "")
+	require.NoError(t, tokens.AutoMigrate())
+
+	owner := models.User{Username: "poller", Email: "poller@example.com", PasswordHash: "x", SubscriptionType: "free"}
+	require.NoError(t, db.Create(&owner).Error)
+	project := models.Project{Name: "poller", OwnerID: owner.ID, Language: "python"}
+	require.NoError(t, db.Create(&project).Error)
+	_, err = tokens.Enable(project.ID, owner.ID)
+	require.NoError(t, err)
+	env, err := tokens.Env(project.ID)
+	require.NoError(t, err)
+	token := env[secrets.ProjectTokenEnvVar]
+
+	storage := &fakeProjectStorage{limit: 10}
+	handler := NewProjectTokenHandler(db, tokens)
+	handler.SetStorageUsage(storage)
+	router := gin.New()
+	handler.RegisterAPIRoutes(router.Group("/api/v1"))
+	put := func(content string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/project-api/outputs/log.txt", strings.NewReader(`{"content":"`+content+`"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, put("12345678").Code)
+	var file models.File
+	require.NoError(t, db.Where("project_id = ? AND path = ?", project.ID, "outputs/log.txt").First(&file).Error)
+	require.Equal(t, "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", file.Hash)
+
+	// Growing past the limit is refused and leaves the file alone
+	w := put("1234567890123")
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), "STORAGE_QUOTA_EXCEEDED")
+	require.NoError(t, db.First(&file, file.ID).Error)
+	require.Equal(t, "12345678", file.Content)
+
+	// Replacing within the limit is charged the difference and versioned
+	require.Equal(t, http.StatusOK, put("123").Code)
+	require.Equal(t, []int64{8, -5}, storage.recorded)
+	var versions int64
+	require.NoError(t, db.Model(&models.FileVersion{}).Where("file_id = ?", file.ID).Count(&versions).Error)
+	require.Equal(t, int64(1), versions)
+}
@@ -378,8 +378,15 @@ func (h *Handler) UpdateProject(c *gin.Context) {
 		}
 		updates["is_public"] = *req.IsPublic
 	}
-	if req.IsArchived != nil {
-		updates["is_archived"] = *req.IsArchived
+	if req.IsArchived != nil && *req.IsArchived != project.IsArchived {
+		// Archiving moves files to cold storage; a bare flag flip would
+		// leave files in place while excluding the project from quota.
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Use POST /projects/:id/archive or /projects/:id/unarchive to change archive state",
+			Code:    "USE_ARCHIVE_ENDPOINT",
+		})
+		return
 	}
 	if req.Environment != nil {
 		updates["environment"] = req.Environment
@@ -15,6 +15,7 @@ import (
 
 	"apex-build/internal/cache"
 	"apex-build/internal/middleware"
+	"apex-build/internal/pagination"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -27,6 +28,19 @@ type OptimizedHandler struct {
 	projectCache *cache.ProjectCache
 	fileCache    *cache.FileCache
 	sessionCache *cache.SessionCache
+	orgScope     OrgProjectScope
+	cacheMetrics CacheMetrics
+}
+
+// CacheMetrics records cache hits and misses. *metrics.Metrics satisfies it.
+type CacheMetrics interface {
+	RecordCacheOperation(cacheName string, hit bool)
+}
+
+// OrgProjectScope resolves organization-owned project visibility.
+// *ownership.Service satisfies it.
+type OrgProjectScope interface {
+	ReadableOrgIDs(ctx context.Context, userID uint) ([]uint, error)
 }
 
 // NewOptimizedHandler creates a new optimized handler with caching
@@ -39,18 +53,55 @@ func NewOptimizedHandler(h *Handler, redisCache *cache.RedisCache) *OptimizedHan
 	}
 }
 
+// SetOrgProjectScope lets listings and lookups include projects owned by
+// organizations the user can read.
+func (oh *OptimizedHandler) SetOrgProjectScope(scope OrgProjectScope) {
+	oh.orgScope = scope
+}
+
+// SetCacheMetrics reports project and file tree cache hits and misses.
+func (oh *OptimizedHandler) SetCacheMetrics(m CacheMetrics) {
+	oh.cacheMetrics = m
+}
+
+// WatchFileMutations invalidates cached file trees whenever files change
+// through db.
+func (oh *OptimizedHandler) WatchFileMutations(db *gorm.DB) error {
+	return oh.fileCache.WatchFileMutations(db)
+}
+
+func (oh *OptimizedHandler) recordCache(name string, hit bool) {
+	if oh.cacheMetrics != nil {
+		oh.cacheMetrics.RecordCacheOperation(name, hit)
+	}
+}
+
+// readableOrgIDs returns the organizations whose projects the user may see.
+// Lookup failures degrade to personal projects only.
+func (oh *OptimizedHandler) readableOrgIDs(ctx context.Context, userID uint) []uint {
+	if oh.orgScope == nil {
+		return nil
+	}
+	orgIDs, err := oh.orgScope.ReadableOrgIDs(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	return orgIDs
+}
+
 // ProjectListItem represents a project in list view with minimal data
 type ProjectListItem struct {
-	ID          uint      `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Language    string    `json:"language"`
-	Framework   string    `json:"framework"`
-	IsPublic    bool      `json:"is_public"`
-	IsArchived  bool      `json:"is_archived"`
-	FileCount   int       `json:"file_count"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             uint      `json:"id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Language       string    `json:"language"`
+	Framework      string    `json:"framework"`
+	IsPublic       bool      `json:"is_public"`
+	IsArchived     bool      `json:"is_archived"`
+	OrganizationID *uint     `json:"organization_id,omitempty"`
+	FileCount      int       `json:"file_count"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 // CursorPagination represents cursor-based pagination info
@@ -94,6 +145,7 @@ func (oh *OptimizedHandler) GetProjectsOptimized(c *gin.Context) {
 		}
 
 		cachedList, err := oh.projectCache.GetProjectList(ctx, userID, page, limit)
+		oh.recordCache("project_list", err == nil)
 		if err == nil {
 			c.JSON(http.StatusOK, gin.H{
 				"projects": cachedList.Projects,
@@ -147,6 +199,7 @@ func (oh *OptimizedHandler) fetchProjectsOptimized(ctx context.Context, userID u
 			projects.framework,
 			projects.is_public,
 			projects.is_archived,
+			projects.organization_id,
 			projects.updated_at,
 			projects.created_at,
 			COALESCE(file_counts.count, 0) as file_count
@@ -157,9 +210,14 @@ func (oh *OptimizedHandler) fetchProjectsOptimized(ctx context.Context, userID u
 			WHERE deleted_at IS NULL
 			GROUP BY project_id
 		) file_counts ON file_counts.project_id = projects.id`).
-		Where("projects.owner_id = ?", userID).
 		Where("projects.deleted_at IS NULL")
 
+	if orgIDs := oh.readableOrgIDs(ctx, userID); len(orgIDs) > 0 {
+		query = query.Where("(projects.owner_id = ? OR projects.organization_id IN ?)", userID, orgIDs)
+	} else {
+		query = query.Where("projects.owner_id = ?", userID)
+	}
+
 	// Apply cursor-based pagination
 	if cursor != nil {
 		// For cursor pagination, we use (updated_at, id) as the cursor key
@@ -175,16 +233,17 @@ func (oh *OptimizedHandler) fetchProjectsOptimized(ctx context.Context, userID u
 
 	// Execute query
 	type projectRow struct {
-		ID          uint
-		Name        string
-		Description string
-		Language    string
-		Framework   string
-		IsPublic    bool
-		IsArchived  bool
-		UpdatedAt   time.Time
-		CreatedAt   time.Time
-		FileCount   int
+		ID             uint
+		Name           string
+		Description    string
+		Language       string
+		Framework      string
+		IsPublic       bool
+		IsArchived     bool
+		OrganizationID *uint
+		UpdatedAt      time.Time
+		CreatedAt      time.Time
+		FileCount      int
 	}
 
 	var rows []projectRow
@@ -202,16 +261,17 @@ func (oh *OptimizedHandler) fetchProjectsOptimized(ctx context.Context, userID u
 	projects := make([]ProjectListItem, len(rows))
 	for i, row := range rows {
 		projects[i] = ProjectListItem{
-			ID:          row.ID,
-			Name:        row.Name,
-			Description: row.Description,
-			Language:    row.Language,
-			Framework:   row.Framework,
-			IsPublic:    row.IsPublic,
-			IsArchived:  row.IsArchived,
-			FileCount:   row.FileCount,
-			UpdatedAt:   row.UpdatedAt,
-			CreatedAt:   row.CreatedAt,
+			ID:             row.ID,
+			Name:           row.Name,
+			Description:    row.Description,
+			Language:       row.Language,
+			Framework:      row.Framework,
+			IsPublic:       row.IsPublic,
+			IsArchived:     row.IsArchived,
+			OrganizationID: row.OrganizationID,
+			FileCount:      row.FileCount,
+			UpdatedAt:      row.UpdatedAt,
+			CreatedAt:      row.CreatedAt,
 		}
 	}
 
@@ -259,9 +319,11 @@ func (oh *OptimizedHandler) GetProjectOptimized(c *gin.Context) {
 
 	// Try cache first
 	cachedProject, err := oh.projectCache.GetProject(ctx, uint(projectID))
+	oh.recordCache("project", err == nil)
 	if err == nil {
 		// Verify access
-		if cachedProject.OwnerID != userID && !cachedProject.IsPublic {
+		if cachedProject.OwnerID != userID && !cachedProject.IsPublic &&
+			!containsOrgID(oh.readableOrgIDs(ctx, userID), cachedProject.OrgID) {
 			c.JSON(http.StatusForbidden, StandardResponse{
 				Success: false,
 				Error:   "Access denied",
@@ -270,6 +332,10 @@ func (oh *OptimizedHandler) GetProjectOptimized(c *gin.Context) {
 			return
 		}
 
+		if middleware.NotModified(c, projectETag(cachedProject.ID, cachedProject.UpdatedAt)) {
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"project": cachedProject,
 			"cached":  true,
@@ -301,11 +367,24 @@ func (oh *OptimizedHandler) GetProjectOptimized(c *gin.Context) {
 	cachedProj := projectToCache(project)
 	oh.projectCache.SetProject(ctx, cachedProj)
 
+	if middleware.NotModified(c, projectETag(project.ID, project.UpdatedAt)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"project": project,
 	})
 }
 
+// projectETag is the weak ETag for project metadata. It is weak because the
+// cached and fresh responses carry the same metadata in different shapes.
+func projectETag(projectID uint, updatedAt time.Time) string {
+	return middleware.WeakETag(
+		strconv.FormatUint(uint64(projectID), 10),
+		updatedAt.UTC().Format(time.RFC3339Nano),
+	)
+}
+
 // fetchProjectOptimized fetches a single project with optimized query
 func (oh *OptimizedHandler) fetchProjectOptimized(ctx context.Context, projectID, userID uint) (*models.Project, error) {
 	var project models.Project
@@ -323,7 +402,8 @@ func (oh *OptimizedHandler) fetchProjectOptimized(ctx context.Context, projectID
 			GROUP BY project_id
 		) file_counts ON file_counts.project_id = projects.id`).
 		Where("projects.id = ?", projectID).
-		Where("(projects.owner_id = ? OR projects.is_public = ?)", userID, true).
+		Where("(projects.owner_id = ? OR projects.is_public = ? OR projects.organization_id IN ?)",
+			userID, true, oh.readableOrgIDs(ctx, userID)).
 		First(&project).Error
 
 	if err != nil {
@@ -387,12 +467,32 @@ func (oh *OptimizedHandler) GetProjectFilesOptimized(c *gin.Context) {
 		return
 	}
 
+	list, err := pagination.Parse(c, fileListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "INVALID_PAGINATION",
+		})
+		return
+	}
+	fileKey := func(sortKey string, id uint, path, name string, size int64, updatedAt time.Time) (any, any) {
+		switch sortKey {
+		case "name":
+			return name, id
+		case "size":
+			return size, id
+		case "updated_at":
+			return updatedAt, id
+		}
+		return path, id
+	}
+
 	includeContent := c.Query("include_content")
 	if includeContent == "true" || includeContent == "1" {
 		var files []models.File
-		err = oh.DB.WithContext(ctx).
-			Where("project_id = ? AND deleted_at IS NULL", projectID).
-			Order("path ASC").
+		err = list.Apply(oh.DB.WithContext(ctx).
+			Where("project_id = ? AND deleted_at IS NULL", projectID)).
 			Find(&files).Error
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, StandardResponse{
@@ -402,34 +502,36 @@ func (oh *OptimizedHandler) GetProjectFilesOptimized(c *gin.Context) {
 			})
 			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"files":           files,
-			"total":           len(files),
-			"include_content": true,
-			"cached":          false,
+		files, pageInfo := pagination.Page(list, files, func(f models.File) (any, any) {
+			return fileKey(list.Sort.Key, f.ID, f.Path, f.Name, f.Size, f.UpdatedAt)
 		})
+		oh.respondFileList(c, files, len(files), list, pageInfo, gin.H{"include_content": true, "cached": false})
 		return
 	}
 
-	// Try cache
-	cachedFiles, err := oh.fileCache.GetFileList(ctx, uint(projectID))
-	if err == nil {
-		c.JSON(http.StatusOK, gin.H{
-			"files":  cachedFiles.Files,
-			"total":  cachedFiles.Total,
-			"cached": true,
-		})
-		return
+	// The full, path-ordered listing is what the file tree asks for; only it
+	// is cached.
+	// Its version is read before loading so a listing that races a mutation
+	// is stored under the superseded version.
+	listsAll := list.Limit == 0 && list.Sort.Key == fileListSpec.Sorts[0].Key && !list.Desc
+	var treeVersion string
+	if listsAll {
+		cachedFiles, err := oh.fileCache.GetFileList(ctx, uint(projectID))
+		oh.recordCache("file_tree", err == nil)
+		if err == nil {
+			_, pageInfo := pagination.Page(list, cachedFiles.Files, nil)
+			oh.respondFileList(c, cachedFiles.Files, cachedFiles.Total, list, pageInfo, gin.H{"cached": true, "tree_version": cachedFiles.TreeVersion})
+			return
+		}
+		treeVersion, _ = oh.fileCache.TreeVersion(ctx, uint(projectID))
 	}
 
 	// Fetch files with selective columns (no content for listing!)
 	var files []cache.CachedFile
-	err = oh.DB.WithContext(ctx).
+	err = list.Apply(oh.DB.WithContext(ctx).
 		Table("files").
 		Select("id, project_id, path, name, type, mime_type, size, version, updated_at").
-		Where("project_id = ? AND deleted_at IS NULL", projectID).
-		Order("path ASC").
+		Where("project_id = ? AND deleted_at IS NULL", projectID)).
 		Scan(&files).Error
 
 	if err != nil {
@@ -441,17 +543,65 @@ func (oh *OptimizedHandler) GetProjectFilesOptimized(c *gin.Context) {
 		return
 	}
 
+	if !listsAll {
+		var total int64
+		oh.DB.WithContext(ctx).Table("files").
+			Where("project_id = ? AND deleted_at IS NULL", projectID).
+			Count(&total)
+		files, pageInfo := pagination.Page(list, files, func(f cache.CachedFile) (any, any) {
+			return fileKey(list.Sort.Key, f.ID, f.Path, f.Name, f.Size, f.UpdatedAt)
+		})
+		oh.respondFileList(c, files, int(total), list, pageInfo, nil)
+		return
+	}
+
 	// Cache the result
-	fileList := &cache.CachedFileList{
-		Files: files,
-		Total: len(files),
+	var extra gin.H
+	if treeVersion != "" {
+		fileList := &cache.CachedFileList{
+			Files: files,
+			Total: len(files),
+		}
+		oh.fileCache.SetFileList(ctx, uint(projectID), treeVersion, fileList)
+		extra = gin.H{"tree_version": treeVersion}
 	}
-	oh.fileCache.SetFileList(ctx, uint(projectID), fileList)
 
-	c.JSON(http.StatusOK, gin.H{
-		"files": files,
-		"total": len(files),
-	})
+	_, pageInfo := pagination.Page(list, files, nil)
+	oh.respondFileList(c, files, len(files), list, pageInfo, extra)
+}
+
+// respondFileList writes a file listing with the requested sparse fieldset.
+func (oh *OptimizedHandler) respondFileList(c *gin.Context, files any, total int, list *pagination.Request, pageInfo pagination.Info, extra gin.H) {
+	selected, err := pagination.SelectFields(files, list.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to select fields",
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
+	response := gin.H{
+		"files":     selected,
+		"total":     total,
+		"page_info": pageInfo,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// fileListSpec is the pagination spec for project file listings. Without a
+// limit the whole tree is returned.
+var fileListSpec = pagination.Spec{
+	MaxLimit: 1000,
+	Sorts: []pagination.Sort{
+		{Key: "path", Column: "path"},
+		{Key: "name", Column: "name"},
+		{Key: "size", Column: "size", Desc: true},
+		{Key: "updated_at", Column: "updated_at", Desc: true},
+	},
 }
 
 // InvalidateProjectCache invalidates cache when a project is modified
@@ -498,6 +648,18 @@ func decodeCursor(encoded string) *ProjectCursor {
 	return &cursor
 }
 
+func containsOrgID(orgIDs []uint, orgID *uint) bool {
+	if orgID == nil {
+		return false
+	}
+	for _, id := range orgIDs {
+		if id == *orgID {
+			return true
+		}
+	}
+	return false
+}
+
 func projectToCache(p *models.Project) *cache.CachedProject {
 	return &cache.CachedProject{
 		ID:           p.ID,
@@ -506,6 +668,7 @@ func projectToCache(p *models.Project) *cache.CachedProject {
 		Language:     p.Language,
 		Framework:    p.Framework,
 		OwnerID:      p.OwnerID,
+		OrgID:        p.OrganizationID,
 		IsPublic:     p.IsPublic,
 		IsArchived:   p.IsArchived,
 		FileCount:    len(p.Files),
@@ -0,0 +1,211 @@
+// Package handlers - Managed Redis browser for APEX.BUILD
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/database"
+	"apex-build/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redisBrowserTimeout bounds one browser request against the shared server
+const redisBrowserTimeout = 15 * time.Second
+
+// redisDatabase loads the Redis namespace named by the route, writing the
+// error response itself when there is none
+func (h *DatabaseHandler) redisDatabase(c *gin.Context) (*database.ManagedDatabase, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return nil, false
+	}
+
+	projectID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	dbID, err := strconv.ParseUint(c.Param("dbId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid database ID",
+			Code:    "INVALID_DATABASE_ID",
+		})
+		return nil, false
+	}
+
+	var managedDB database.ManagedDatabase
+	if err := h.DB.Where("id = ? AND project_id = ? AND user_id = ?", dbID, projectID, userID).First(&managedDB).Error; err != nil {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   "Database not found",
+			Code:    "NOT_FOUND",
+		})
+		return nil, false
+	}
+	if managedDB.Type != database.DatabaseTypeRedis {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "The Redis browser is only available for Redis databases",
+			Code:    "NOT_REDIS",
+		})
+		return nil, false
+	}
+	return &managedDB, true
+}
+
+func redisBrowserError(c *gin.Context, action string, err error) {
+	c.JSON(http.StatusBadGateway, StandardResponse{
+		Success: false,
+		Error:   "Failed to " + action + ": " + err.Error(),
+		Code:    "REDIS_ERROR",
+	})
+}
+
+// GetRedisKeys returns one page of a Redis namespace's keys
+func (h *DatabaseHandler) GetRedisKeys(c *gin.Context) {
+	managedDB, ok := h.redisDatabase(c)
+	if !ok {
+		return
+	}
+	cursor, err := strconv.ParseUint(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid cursor",
+			Code:    "INVALID_CURSOR",
+		})
+		return
+	}
+	count, _ := strconv.Atoi(c.Query("count"))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redisBrowserTimeout)
+	defer cancel()
+	page, err := h.Manager.ScanRedisKeys(ctx, managedDB, c.Query("pattern"), cursor, count)
+	if err != nil {
+		redisBrowserError(c, "scan keys", err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{Success: true, Data: page})
+}
+
+// GetRedisQueues returns the lengths of a Redis namespace's queues
+func (h *DatabaseHandler) GetRedisQueues(c *gin.Context) {
+	managedDB, ok := h.redisDatabase(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redisBrowserTimeout)
+	defer cancel()
+	report, err := h.Manager.RedisQueues(ctx, managedDB)
+	if err != nil {
+		redisBrowserError(c, "inspect queues", err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{Success: true, Data: report})
+}
+
+// GetRedisChannels lists a Redis namespace's active pub/sub channels
+func (h *DatabaseHandler) GetRedisChannels(c *gin.Context) {
+	managedDB, ok := h.redisDatabase(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redisBrowserTimeout)
+	defer cancel()
+	channels, err := h.Manager.RedisChannels(ctx, managedDB, c.Query("pattern"))
+	if err != nil {
+		redisBrowserError(c, "list channels", err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{Success: true, Data: gin.H{"channels": channels}})
+}
+
+// PeekRedisChannel listens on a channel for a few seconds and returns the
+// messages published meanwhile
+func (h *DatabaseHandler) PeekRedisChannel(c *gin.Context) {
+	managedDB, ok := h.redisDatabase(c)
+	if !ok {
+		return
+	}
+	channel := c.Query("channel")
+	if channel == "" {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "channel is required",
+			Code:    "INVALID_REQUEST",
+		})
+		return
+	}
+	wait := database.DefaultRedisPeekWait
+	if seconds, err := strconv.Atoi(c.Query("wait")); err == nil && seconds > 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+	max, _ := strconv.Atoi(c.Query("max"))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redisBrowserTimeout)
+	defer cancel()
+	messages, err := h.Manager.PeekRedisChannel(ctx, managedDB, channel, wait, max)
+	if err != nil {
+		redisBrowserError(c, "peek channel", err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Data: gin.H{
+			"channel":  channel,
+			"messages": messages,
+		},
+	})
+}
+
+// GetRedisMemory returns the memory a Redis namespace uses
+func (h *DatabaseHandler) GetRedisMemory(c *gin.Context) {
+	managedDB, ok := h.redisDatabase(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redisBrowserTimeout)
+	defer cancel()
+	stats, err := h.Manager.RedisMemory(ctx, managedDB)
+	if err != nil {
+		redisBrowserError(c, "read memory stats", err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{Success: true, Data: stats})
+}
+
+// FlushRedis deletes every key of a Redis namespace. The database name must
+// be passed as confirm so a stray request cannot wipe it.
+func (h *DatabaseHandler) FlushRedis(c *gin.Context) {
+	managedDB, ok := h.redisDatabase(c)
+	if !ok {
+		return
+	}
+	if c.Query("confirm") != managedDB.Name {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Pass the database name as confirm to flush it",
+			Code:    "CONFIRMATION_REQUIRED",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redisBrowserTimeout)
+	defer cancel()
+	deleted, err := h.Manager.FlushRedisNamespace(ctx, managedDB)
+	if err != nil {
+		redisBrowserError(c, "flush database", err)
+		return
+	}
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Data:    gin.H{"deleted_keys": deleted},
+	})
+}
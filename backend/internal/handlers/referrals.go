@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"apex-build/internal/auth"
+	"apex-build/internal/middleware"
+	"apex-build/internal/referrals"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// referralCookieMaxAge is how long a link click is remembered for signup
+const referralCookieMaxAge = 30 * 24 * time.Hour
+
+// Referral link clicks are public; limit them per IP so the click counts
+// cannot be inflated cheaply
+const (
+	referralClicksPerMinute = 30
+	referralClickBurst      = 10
+)
+
+// ReferralHandler serves referral links and the referrer dashboard
+type ReferralHandler struct {
+	service *referrals.Service
+	limiter *middleware.IPRateLimiter
+}
+
+// NewReferralHandler creates a new ReferralHandler
+func NewReferralHandler(service *referrals.Service) *ReferralHandler {
+	return &ReferralHandler{
+		service: service,
+		limiter: middleware.NewScopedIPRateLimiter(rate.Limit(referralClicksPerMinute)/60, referralClickBurst, "referral_clicks"),
+	}
+}
+
+// referralLink is the tracked link a referrer shares
+func referralLink(c *gin.Context, code string) string {
+	return publicAPIBaseURL(c) + "/api/v1/r/" + url.PathEscape(code)
+}
+
+// signupURL is the frontend signup page with the code prefilled
+func signupURL(code string) string {
+	return configuredAppURL() + "/register?ref=" + url.QueryEscape(code)
+}
+
+// FollowReferralLink counts a click, remembers the code in a cookie and
+// sends the visitor to signup. Unknown codes still land on signup.
+// GET /r/:code
+func (h *ReferralHandler) FollowReferralLink(c *gin.Context) {
+	if !h.limiter.Allow(c.ClientIP()) {
+		c.Redirect(http.StatusFound, configuredAppURL()+"/register")
+		return
+	}
+	code, err := h.service.RecordClick(c.Param("code"), c.ClientIP(), c.GetHeader(auth.DeviceIDHeader))
+	if err != nil {
+		c.Redirect(http.StatusFound, configuredAppURL()+"/register")
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     referrals.CookieName,
+		Value:    code.Code,
+		Path:     "/",
+		MaxAge:   int(referralCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   previewCookieSecure(c),
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusFound, signupURL(code.Code))
+}
+
+// GetReferralDashboard returns the caller's referral link with clicks,
+// signups, conversions and earned credits.
+// GET /referrals
+func (h *ReferralHandler) GetReferralDashboard(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	dashboard, err := h.service.Dashboard(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load referrals"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"link":       referralLink(c, dashboard.Code),
+		"signup_url": signupURL(dashboard.Code),
+		"dashboard":  dashboard,
+	}})
+}
+
+// RegisterPublicRoutes registers the unauthenticated referral link route
+func (h *ReferralHandler) RegisterPublicRoutes(rg *gin.RouterGroup) {
+	rg.GET("/r/:code", h.FollowReferralLink)
+}
+
+// RegisterRoutes registers the referral dashboard on an authenticated group
+func (h *ReferralHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/referrals", h.GetReferralDashboard)
+}
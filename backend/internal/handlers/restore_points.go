@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/restorepoints"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RestorePointSnapshotter takes a project restore point on the given handle,
+// which may be a transaction. Implemented by *restorepoints.Service.
+type RestorePointSnapshotter interface {
+	SnapshotProject(tx *gorm.DB, projectID, userID uint, reason, label string) error
+}
+
+// RestorePointHandler serves project-level restore points: snapshots of the
+// whole file tree that can be compared and restored, in full or one
+// directory at a time.
+type RestorePointHandler struct {
+	service *restorepoints.Service
+	access  collaboration.AccessResolver
+}
+
+// NewRestorePointHandler creates a new RestorePointHandler. Anyone with
+// access to the project may list and compare its restore points; creating
+// and restoring them requires edit access.
+func NewRestorePointHandler(service *restorepoints.Service, access collaboration.AccessResolver) *RestorePointHandler {
+	return &RestorePointHandler{service: service, access: access}
+}
+
+// CreateRestorePointRequest is the body for taking a manual restore point
+type CreateRestorePointRequest struct {
+	Label string `json:"label" binding:"max=255"`
+}
+
+// RestoreToPointRequest is the body for restoring a restore point
+type RestoreToPointRequest struct {
+	Directory string `json:"directory"`
+}
+
+// TagRestorePointRequest names a restore point. An empty tag removes it.
+type TagRestorePointRequest struct {
+	Tag       string `json:"tag"`
+	Protected *bool  `json:"protected"` // defaults to true for new tags
+}
+
+// ListRestorePoints returns a project's restore points, newest first.
+// GET /projects/:id/restore-points?limit=50&tagged=true
+func (h *RestorePointHandler) ListRestorePoints(c *gin.Context) {
+	projectID, _, ok := h.authorize(c, nil)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	points, err := h.service.List(c.Request.Context(), projectID, limit, c.Query("tagged") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load restore points"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"restore_points": points}})
+}
+
+// CreateRestorePoint takes a manual restore point of the project.
+// POST /projects/:id/restore-points
+func (h *RestorePointHandler) CreateRestorePoint(c *gin.Context) {
+	projectID, access, ok := h.authorize(c, canEditProject)
+	if !ok {
+		return
+	}
+	var req CreateRestorePointRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request", "details": err.Error()})
+			return
+		}
+	}
+	point, err := h.service.Create(c.Request.Context(), projectID, access.userID, restorepoints.ReasonManual, strings.TrimSpace(req.Label))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create restore point"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"restore_point": point}})
+}
+
+// DiffRestorePoints compares two restore points across the whole tree, or
+// one restore point against the current files when to is omitted.
+// GET /projects/:id/restore-points/diff?from=12&to=15&directory=src
+func (h *RestorePointHandler) DiffRestorePoints(c *gin.Context) {
+	projectID, _, ok := h.authorize(c, nil)
+	if !ok {
+		return
+	}
+	fromID, err := strconv.ParseUint(c.Query("from"), 10, 32)
+	if err != nil || fromID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from must be a restore point id"})
+		return
+	}
+	var toID uint64
+	if raw := c.Query("to"); raw != "" && raw != "current" {
+		if toID, err = strconv.ParseUint(raw, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must be a restore point id or \"current\""})
+			return
+		}
+	}
+
+	changes, err := h.service.Diff(c.Request.Context(), projectID, uint(fromID), uint(toID), c.Query("directory"))
+	if err != nil {
+		h.respondError(c, err, "Failed to compare restore points")
+		return
+	}
+	summary := gin.H{"added": 0, "removed": 0, "modified": 0}
+	for _, change := range changes {
+		summary[change.Status] = summary[change.Status].(int) + 1
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"from":    fromID,
+		"to":      toID,
+		"changes": changes,
+		"summary": summary,
+	}})
+}
+
+// RestoreToPoint restores the project, or one directory, to a restore point.
+// Protected points can only be restored by project admins.
+// POST /projects/:id/restore-points/:pointId/restore
+func (h *RestorePointHandler) RestoreToPoint(c *gin.Context) {
+	projectID, access, point, ok := h.authorizePoint(c)
+	if !ok {
+		return
+	}
+	if point.IsProtected && !canAdminProject(access.permission) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only project admins can restore protected restore points"})
+		return
+	}
+	var req RestoreToPointRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request", "details": err.Error()})
+			return
+		}
+	}
+
+	result, err := h.service.Restore(c.Request.Context(), projectID, point.ID, access.userID, c.GetString("username"), req.Directory)
+	if err != nil {
+		h.respondError(c, err, "Failed to restore project")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// TagRestorePoint tags a restore point, or removes its tag. Removing or
+// unprotecting a protected tag requires a project admin.
+// PUT /projects/:id/restore-points/:pointId/tag
+func (h *RestorePointHandler) TagRestorePoint(c *gin.Context) {
+	projectID, access, point, ok := h.authorizePoint(c)
+	if !ok {
+		return
+	}
+	var req TagRestorePointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request", "details": err.Error()})
+		return
+	}
+	req.Tag = strings.TrimSpace(req.Tag)
+	if req.Tag != "" && !versionTagPattern.MatchString(req.Tag) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Tags are up to 100 letters, digits, dots, dashes, underscores or plus signs"})
+		return
+	}
+	protected := req.Tag != "" && (req.Protected == nil || *req.Protected)
+	if point.IsProtected && !protected && !canAdminProject(access.permission) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only project admins can remove or unprotect a protected tag"})
+		return
+	}
+
+	point, err := h.service.Tag(c.Request.Context(), projectID, point.ID, access.userID, req.Tag, protected)
+	if errors.Is(err, restorepoints.ErrTagExists) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Another restore point of this project has that tag"})
+		return
+	}
+	if err != nil {
+		h.respondError(c, err, "Failed to tag restore point")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"restore_point": point}})
+}
+
+// DeleteRestorePoint deletes a restore point. Protected points can only be
+// deleted by project admins.
+// DELETE /projects/:id/restore-points/:pointId
+func (h *RestorePointHandler) DeleteRestorePoint(c *gin.Context) {
+	projectID, access, point, ok := h.authorizePoint(c)
+	if !ok {
+		return
+	}
+	if point.IsProtected && !canAdminProject(access.permission) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only project admins can delete protected restore points"})
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), projectID, point.ID); err != nil {
+		h.respondError(c, err, "Failed to delete restore point")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"deleted": point.ID}})
+}
+
+// RegisterRoutes mounts restore points on the protected API group.
+func (h *RestorePointHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/restore-points", h.ListRestorePoints)
+	rg.POST("/projects/:id/restore-points", h.CreateRestorePoint)
+	rg.GET("/projects/:id/restore-points/diff", h.DiffRestorePoints)
+	rg.POST("/projects/:id/restore-points/:pointId/restore", h.RestoreToPoint)
+	rg.PUT("/projects/:id/restore-points/:pointId/tag", h.TagRestorePoint)
+	rg.DELETE("/projects/:id/restore-points/:pointId", h.DeleteRestorePoint)
+}
+
+// restorePointCaller is the authenticated caller and their project permission
+type restorePointCaller struct {
+	userID     uint
+	permission collaboration.PermissionLevel
+}
+
+// authorize resolves the project from the path and checks the caller may
+// read it and, when allowed is set, that it accepts their permission
+func (h *RestorePointHandler) authorize(c *gin.Context, allowed func(collaboration.PermissionLevel) bool) (uint, restorePointCaller, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, restorePointCaller{}, false
+	}
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project id"})
+		return 0, restorePointCaller{}, false
+	}
+	access, err := h.access(userID, uint(projectID))
+	if err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Project not found"})
+		case errors.Is(err, collaboration.ErrProjectAccessDenied):
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check project access"})
+		}
+		return 0, restorePointCaller{}, false
+	}
+	if allowed != nil && !allowed(access.Permission) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Edit access required"})
+		return 0, restorePointCaller{}, false
+	}
+	return uint(projectID), restorePointCaller{userID: userID, permission: access.Permission}, true
+}
+
+// authorizePoint checks the caller may edit the project and loads the
+// restore point named by :pointId
+func (h *RestorePointHandler) authorizePoint(c *gin.Context) (uint, restorePointCaller, *restorepoints.Point, bool) {
+	projectID, caller, ok := h.authorize(c, canEditProject)
+	if !ok {
+		return 0, caller, nil, false
+	}
+	pointID, err := strconv.ParseUint(c.Param("pointId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid restore point id"})
+		return 0, caller, nil, false
+	}
+	point, err := h.service.Get(c.Request.Context(), projectID, uint(pointID))
+	if err != nil {
+		h.respondError(c, err, "Failed to load restore point")
+		return 0, caller, nil, false
+	}
+	return projectID, caller, point, true
+}
+
+func (h *RestorePointHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, restorepoints.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Restore point not found"})
+	case errors.Is(err, restorepoints.ErrInvalidDirectory):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid directory"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": fallback})
+	}
+}
+
+// snapshotBeforeChange takes an automatic restore point before a bulk
+// change. Failures are logged: a missing restore point never blocks the
+// change itself.
+func snapshotBeforeChange(db *gorm.DB, points RestorePointSnapshotter, projectID, userID uint, reason, label string) {
+	if points == nil || db == nil {
+		return
+	}
+	if err := points.SnapshotProject(db, projectID, userID, reason, label); err != nil {
+		log.Printf("restore points: failed to snapshot project %d before %s: %v", projectID, reason, err)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/retention"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler lets admins inspect retention policies, override them per
+// user or project and run the pruning job on demand
+type RetentionHandler struct {
+	service *retention.Service
+}
+
+// NewRetentionHandler creates a new RetentionHandler
+func NewRetentionHandler(service *retention.Service) *RetentionHandler {
+	return &RetentionHandler{service: service}
+}
+
+// GetRetentionPolicies returns the plan defaults and every override.
+// GET /admin/retention/policies
+func (h *RetentionHandler) GetRetentionPolicies(c *gin.Context) {
+	overrides, err := h.service.Overrides(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load overrides"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"plans":     retention.PlanPolicies(),
+		"overrides": overrides,
+	}})
+}
+
+// SetRetentionOverride creates or replaces the override for a user or
+// project. Omitted limits keep the plan default; 0 keeps forever.
+// PUT /admin/retention/overrides
+func (h *RetentionHandler) SetRetentionOverride(c *gin.Context) {
+	var req struct {
+		Scope            string `json:"scope" binding:"required"`
+		ScopeID          uint   `json:"scope_id" binding:"required"`
+		VersionDays      *int   `json:"version_days"`
+		VersionsPerFile  *int   `json:"versions_per_file"`
+		RestorePointDays *int   `json:"restore_point_days"`
+		BuildDays        *int   `json:"build_days"`
+		Exempt           bool   `json:"exempt"`
+		Note             string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "scope and scope_id are required"})
+		return
+	}
+	saved, err := h.service.SetOverride(c.Request.Context(), retention.Override{
+		Scope:            req.Scope,
+		ScopeID:          req.ScopeID,
+		VersionDays:      req.VersionDays,
+		VersionsPerFile:  req.VersionsPerFile,
+		RestorePointDays: req.RestorePointDays,
+		BuildDays:        req.BuildDays,
+		Exempt:           req.Exempt,
+		Note:             req.Note,
+		SetByID:          c.GetUint("user_id"),
+	})
+	switch {
+	case errors.Is(err, retention.ErrInvalidOverride):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "scope must be user or project and limits must not be negative"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save override"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": saved})
+}
+
+// DeleteRetentionOverride removes an override, restoring the plan default.
+// DELETE /admin/retention/overrides/:id
+func (h *RetentionHandler) DeleteRetentionOverride(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid override id"})
+		return
+	}
+	err = h.service.DeleteOverride(c.Request.Context(), uint(id))
+	switch {
+	case errors.Is(err, retention.ErrOverrideNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to delete override"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RunRetention runs the pruning job now. It is a dry run unless dry_run is
+// explicitly false.
+// POST /admin/retention/run
+func (h *RetentionHandler) RunRetention(c *gin.Context) {
+	var req struct {
+		DryRun *bool `json:"dry_run"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request"})
+			return
+		}
+	}
+	dryRun := req.DryRun == nil || *req.DryRun
+	adminID := c.GetUint("user_id")
+	report, err := h.service.Prune(c.Request.Context(), dryRun, &adminID)
+	switch {
+	case errors.Is(err, retention.ErrRunning):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Retention run failed", "data": report})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// ListRetentionRuns returns recent runs, newest first.
+// GET /admin/retention/runs?limit=20
+func (h *RetentionHandler) ListRetentionRuns(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runs, err := h.service.Runs(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"runs": runs}})
+}
+
+// RegisterAdminRoutes registers the retention endpoints on the admin group
+func (h *RetentionHandler) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/retention/policies", h.GetRetentionPolicies)
+	admin.PUT("/retention/overrides", h.SetRetentionOverride)
+	admin.DELETE("/retention/overrides/:id", h.DeleteRetentionOverride)
+	admin.POST("/retention/run", h.RunRetention)
+	admin.GET("/retention/runs", h.ListRetentionRuns)
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"apex-build/internal/config"
+	"apex-build/internal/middleware"
+	"apex-build/internal/secrets"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -11,7 +15,8 @@ import (
 
 // RotationHandler handles admin secret rotation endpoints
 type RotationHandler struct {
-	db *gorm.DB
+	db   *gorm.DB
+	jobs *secrets.RotationRunner
 }
 
 // NewRotationHandler creates a new rotation handler
@@ -19,6 +24,11 @@ func NewRotationHandler(db *gorm.DB) *RotationHandler {
 	return &RotationHandler{db: db}
 }
 
+// SetRotationRunner enables online rotation jobs
+func (h *RotationHandler) SetRotationRunner(runner *secrets.RotationRunner) {
+	h.jobs = runner
+}
+
 // RotateSecretsRequest is the request body for key rotation
 type RotateSecretsRequest struct {
 	OldMasterKey string `json:"old_master_key" binding:"required"`
@@ -73,3 +83,91 @@ func (h *RotationHandler) ValidateSecrets(c *gin.Context) {
 		"healthy":     fail == 0,
 	})
 }
+
+func (h *RotationHandler) rotationJobs(c *gin.Context) bool {
+	if h.jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Online key rotation is not available"})
+		return false
+	}
+	return true
+}
+
+func rotationJobResponse(job *secrets.RotationJob) gin.H {
+	return gin.H{"job": job, "progress": job.Progress()}
+}
+
+// StartSecretRotation starts an online rotation from SECRETS_MASTER_KEY_OLD
+// to SECRETS_MASTER_KEY. Both keys decrypt while it runs.
+// POST /api/v1/admin/secret-rotations
+// Requires: super admin
+func (h *RotationHandler) StartSecretRotation(c *gin.Context) {
+	if !h.rotationJobs(c) {
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+	job, err := h.jobs.Start(userID)
+	switch {
+	case errors.Is(err, secrets.ErrNoPreviousKey):
+		c.JSON(http.StatusConflict, gin.H{"error": "Set SECRETS_MASTER_KEY_OLD to the previous key and SECRETS_MASTER_KEY to the new key, then restart"})
+		return
+	case errors.Is(err, secrets.ErrRotationInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, rotationJobResponse(job))
+}
+
+// ListSecretRotations lists recent rotation jobs
+// GET /api/v1/admin/secret-rotations
+// Requires: super admin
+func (h *RotationHandler) ListSecretRotations(c *gin.Context) {
+	if !h.rotationJobs(c) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	jobs, err := h.jobs.List(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetSecretRotation reports a rotation job's progress
+// GET /api/v1/admin/secret-rotations/:id
+// Requires: super admin
+func (h *RotationHandler) GetSecretRotation(c *gin.Context) {
+	h.secretRotationAction(c, h.jobs.Get)
+}
+
+// CancelSecretRotation stops a running rotation job. Values already moved
+// stay under the new key; both keys keep decrypting the rest.
+// POST /api/v1/admin/secret-rotations/:id/cancel
+// Requires: super admin
+func (h *RotationHandler) CancelSecretRotation(c *gin.Context) {
+	h.secretRotationAction(c, h.jobs.Cancel)
+}
+
+func (h *RotationHandler) secretRotationAction(c *gin.Context, action func(uint) (*secrets.RotationJob, error)) {
+	if !h.rotationJobs(c) {
+		return
+	}
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	job, err := action(uint(jobID))
+	if errors.Is(err, secrets.ErrRotationJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rotationJobResponse(job))
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/schedules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler serves per-project scheduled tasks (cron runs of a command
+// in the project's environment) and their run history.
+type ScheduleHandler struct {
+	service *schedules.Service
+}
+
+// NewScheduleHandler creates a new ScheduleHandler.
+func NewScheduleHandler(service *schedules.Service) *ScheduleHandler {
+	return &ScheduleHandler{service: service}
+}
+
+// ListSchedules returns a project's scheduled tasks.
+// GET /projects/:id/schedules
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	userID, projectID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.service.List(c.Request.Context(), userID, projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load scheduled tasks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"schedules": tasks}})
+}
+
+// CreateSchedule adds a scheduled task to a project.
+// POST /projects/:id/schedules
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	userID, projectID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	var in schedules.TaskInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	task, err := h.service.Create(c.Request.Context(), userID, projectID, in)
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"schedule": task}})
+}
+
+// UpdateSchedule edits or enables/disables a scheduled task.
+// PATCH /schedules/:id
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	userID, taskID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	var in schedules.TaskInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	task, err := h.service.Update(c.Request.Context(), userID, taskID, in)
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"schedule": task}})
+}
+
+// DeleteSchedule removes a scheduled task and its run history.
+// DELETE /schedules/:id
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	userID, taskID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), userID, taskID); err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RunSchedule starts a scheduled task now.
+// POST /schedules/:id/run
+func (h *ScheduleHandler) RunSchedule(c *gin.Context) {
+	userID, taskID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.service.RunNow(c.Request.Context(), userID, taskID)
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": gin.H{"run": run}})
+}
+
+// ListScheduleRuns returns a task's run history without logs.
+// GET /schedules/:id/runs
+func (h *ScheduleHandler) ListScheduleRuns(c *gin.Context) {
+	userID, taskID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runs, err := h.service.ListRuns(c.Request.Context(), userID, taskID, limit)
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"runs": runs}})
+}
+
+// GetScheduleRun returns one run with its logs.
+// GET /schedules/:id/runs/:runId
+func (h *ScheduleHandler) GetScheduleRun(c *gin.Context) {
+	userID, taskID, ok := h.parseScheduleRequest(c)
+	if !ok {
+		return
+	}
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid run id"})
+		return
+	}
+
+	run, err := h.service.GetRun(c.Request.Context(), userID, taskID, uint(runID))
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"run": run}})
+}
+
+// RegisterRoutes registers the scheduled task endpoints.
+func (h *ScheduleHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/schedules", h.ListSchedules)
+	rg.POST("/projects/:id/schedules", h.CreateSchedule)
+
+	sg := rg.Group("/schedules")
+	{
+		sg.PATCH("/:id", h.UpdateSchedule)
+		sg.DELETE("/:id", h.DeleteSchedule)
+		sg.POST("/:id/run", h.RunSchedule)
+		sg.GET("/:id/runs", h.ListScheduleRuns)
+		sg.GET("/:id/runs/:runId", h.GetScheduleRun)
+	}
+}
+
+func (h *ScheduleHandler) parseScheduleRequest(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid id"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func (h *ScheduleHandler) respondScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, schedules.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, schedules.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, schedules.ErrAlreadyRunning):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Scheduled task operation failed"})
+	}
+}
@@ -2,31 +2,69 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"apex-build/internal/collaboration"
+	"apex-build/internal/middleware"
+	"apex-build/internal/restorepoints"
 	"apex-build/internal/search"
 	"apex-build/pkg/models"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
 	"gorm.io/gorm"
 )
 
+// Workspace searches read every project a user can access, so they are
+// limited per user
+const (
+	workspaceSearchesPerMinute = 10
+	workspaceSearchBurst       = 5
+)
+
 // SearchHandler handles code search endpoints
 type SearchHandler struct {
-	engine *search.SearchEngine
-	db     *gorm.DB
+	engine  *search.SearchEngine
+	indexer *search.Indexer
+	db      *gorm.DB
+
+	restorePoints RestorePointSnapshotter
+	orgPerms      collaboration.OrgPermissions
+	workspace     *middleware.IPRateLimiter
 }
 
 // NewSearchHandler creates a new search handler
 func NewSearchHandler(engine *search.SearchEngine, db ...*gorm.DB) *SearchHandler {
-	h := &SearchHandler{engine: engine}
+	h := &SearchHandler{
+		engine:    engine,
+		workspace: middleware.NewScopedIPRateLimiter(rate.Limit(workspaceSearchesPerMinute)/60, workspaceSearchBurst, "workspace_search"),
+	}
 	if len(db) > 0 {
 		h.db = db[0]
 	}
 	return h
 }
 
+// SetRestorePoints makes project-wide replacements take a project restore
+// point before any file is rewritten.
+func (h *SearchHandler) SetRestorePoints(points RestorePointSnapshotter) {
+	h.restorePoints = points
+}
+
+// SetOrgPermissions lets workspace searches include organization projects
+// the user holds projects:read on.
+func (h *SearchHandler) SetOrgPermissions(perms collaboration.OrgPermissions) {
+	h.orgPerms = perms
+}
+
+// SetIndexer enables the search index stats and rebuild endpoints.
+func (h *SearchHandler) SetIndexer(indexer *search.Indexer) {
+	h.indexer = indexer
+}
+
 // Search handles POST /api/v1/search
 // Performs comprehensive code search across project files
 func (h *SearchHandler) Search(c *gin.Context) {
@@ -57,6 +95,115 @@ func (h *SearchHandler) Search(c *gin.Context) {
 	})
 }
 
+// WorkspaceSearchRequest is a search across projects. Scope "user" covers
+// the user's own projects and those of every organization they can read;
+// scope "org" covers one organization's projects.
+type WorkspaceSearchRequest struct {
+	search.SearchQuery
+	Scope          string `json:"scope"`
+	OrganizationID uint   `json:"organization_id"`
+}
+
+// SearchWorkspace handles POST /api/v1/search/workspace
+// Searches every accessible project in the user's or an organization's
+// workspace and groups the matches by project
+func (h *SearchHandler) SearchWorkspace(c *gin.Context) {
+	var req WorkspaceSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if userID == 0 || h.db == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = "user"
+	}
+
+	var orgIDs []uint
+	switch req.Scope {
+	case "user":
+		ids, err := h.readableOrganizations(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organizations"})
+			return
+		}
+		orgIDs = ids
+	case "org":
+		if req.OrganizationID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "organization_id is required for org scope"})
+			return
+		}
+		if h.orgPerms == nil || !h.orgPerms.HasPermission(req.OrganizationID, userID, "projects", "read") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to this organization"})
+			return
+		}
+		orgIDs = []uint{req.OrganizationID}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be \"user\" or \"org\""})
+		return
+	}
+
+	if !h.workspace.Allow(fmt.Sprintf("user:%d", userID)) {
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many workspace searches. Please try again later.", "code": "RATE_LIMITED"})
+		return
+	}
+
+	db := h.db.WithContext(c.Request.Context()).Model(&models.Project{}).Select("id", "name", "organization_id")
+	if req.Scope == "org" {
+		db = db.Where("organization_id = ?", req.OrganizationID)
+	} else if len(orgIDs) > 0 {
+		db = db.Where("owner_id = ? OR organization_id IN ?", userID, orgIDs)
+	} else {
+		db = db.Where("owner_id = ?", userID)
+	}
+	var projects []models.Project
+	if err := db.Order("updated_at DESC").Limit(search.MaxWorkspaceProjects + 1).Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load projects"})
+		return
+	}
+	scope := make([]search.WorkspaceProject, 0, len(projects))
+	for _, project := range projects {
+		scope = append(scope, search.WorkspaceProject{ID: project.ID, Name: project.Name, OrganizationID: project.OrganizationID})
+	}
+
+	results, err := h.engine.SearchWorkspace(c.Request.Context(), scope, req.SearchQuery)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"scope":   req.Scope,
+		"results": results,
+	})
+}
+
+// readableOrganizations returns the organizations whose projects the user
+// may read
+func (h *SearchHandler) readableOrganizations(userID uint) ([]uint, error) {
+	if h.orgPerms == nil {
+		return nil, nil
+	}
+	var memberships []uint
+	err := h.db.Table("organization_members").
+		Where("user_id = ? AND status = ? AND deleted_at IS NULL", userID, "active").
+		Pluck("organization_id", &memberships).Error
+	if err != nil {
+		return nil, err
+	}
+	readable := make([]uint, 0, len(memberships))
+	for _, orgID := range memberships {
+		if h.orgPerms.HasPermission(orgID, userID, "projects", "read") {
+			readable = append(readable, orgID)
+		}
+	}
+	return readable, nil
+}
+
 // QuickSearch handles GET /api/v1/search/quick
 // Fast search with minimal options for autocomplete/instant search
 func (h *SearchHandler) QuickSearch(c *gin.Context) {
@@ -231,6 +378,7 @@ func (h *SearchHandler) SearchAndReplace(c *gin.Context) {
 	if req.Preview {
 		results, err = h.engine.SearchAndReplace(c.Request.Context(), req.ProjectID, req.Search, req.Replace, options)
 	} else {
+		snapshotBeforeChange(h.db, h.restorePoints, req.ProjectID, userID, restorepoints.ReasonBulk, fmt.Sprintf("Before replacing %q", req.Search))
 		results, err = h.engine.ApplyReplacements(c.Request.Context(), req.ProjectID, req.Search, req.Replace, options)
 	}
 
@@ -245,6 +393,132 @@ func (h *SearchHandler) SearchAndReplace(c *gin.Context) {
 	})
 }
 
+// ReplaceRequest is the body of the replace preview and apply endpoints
+type ReplaceRequest struct {
+	ProjectID     uint     `json:"project_id" binding:"required"`
+	Search        string   `json:"search" binding:"required"`
+	Replace       string   `json:"replace"`
+	CaseSensitive bool     `json:"case_sensitive"`
+	WholeWord     bool     `json:"whole_word"`
+	UseRegex      bool     `json:"use_regex"`
+	FileTypes     []string `json:"file_types"`
+	Paths         []string `json:"paths"`
+	// Selections limits apply to the previewed matches picked per file.
+	// Omitted, every current match is replaced.
+	Selections []search.ReplaceSelection `json:"selections"`
+}
+
+func (r *ReplaceRequest) spec() search.ReplaceSpec {
+	return search.ReplaceSpec{
+		ProjectID:     r.ProjectID,
+		Search:        r.Search,
+		Replace:       r.Replace,
+		CaseSensitive: r.CaseSensitive,
+		WholeWord:     r.WholeWord,
+		UseRegex:      r.UseRegex,
+		FileTypes:     r.FileTypes,
+		Paths:         r.Paths,
+	}
+}
+
+// PreviewReplace handles POST /api/v1/search/replace/preview
+// Lists every match with its line before and after replacement, expanding
+// regex capture groups, without writing anything
+func (h *SearchHandler) PreviewReplace(c *gin.Context) {
+	var req ReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.userOwnsProject(c.GetUint("user_id"), req.ProjectID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to this project"})
+		return
+	}
+
+	preview, err := h.engine.PreviewReplace(c.Request.Context(), req.spec())
+	if err != nil {
+		h.writeReplaceError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"preview": preview,
+	})
+}
+
+// ApplyReplace handles POST /api/v1/search/replace/apply
+// Replaces the selected matches and returns an undo token. Files changed
+// since the preview are reported as conflicts and nothing is written
+func (h *SearchHandler) ApplyReplace(c *gin.Context) {
+	var req ReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if !h.userIsProjectOwner(userID, req.ProjectID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to this project"})
+		return
+	}
+
+	snapshotBeforeChange(h.db, h.restorePoints, req.ProjectID, userID, restorepoints.ReasonBulk, fmt.Sprintf("Before replacing %q", req.Search))
+	result, err := h.engine.ApplyReplace(c.Request.Context(), req.spec(), req.Selections, userID, h.username(userID))
+	if err != nil {
+		h.writeReplaceError(c, err, result)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// UndoReplace handles POST /api/v1/search/replace/undo
+// Restores the files a replacement changed to their versions from before it
+func (h *SearchHandler) UndoReplace(c *gin.Context) {
+	var req struct {
+		ProjectID uint   `json:"project_id" binding:"required"`
+		UndoToken string `json:"undo_token" binding:"required"`
+		Force     bool   `json:"force"` // also restore files edited since
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if !h.userIsProjectOwner(userID, req.ProjectID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to this project"})
+		return
+	}
+
+	result, err := h.engine.UndoReplace(c.Request.Context(), req.ProjectID, req.UndoToken, req.Force, userID, h.username(userID))
+	if err != nil {
+		h.writeReplaceError(c, err, result)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
+
+func (h *SearchHandler) writeReplaceError(c *gin.Context, err error, result any) {
+	switch {
+	case errors.Is(err, search.ErrInvalidPattern):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, search.ErrReplaceConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "result": result})
+	case errors.Is(err, search.ErrUndoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, search.ErrUndoExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, search.ErrAlreadyUndone):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace"})
+	}
+}
+
 // GetSearchHistory handles GET /api/v1/search/history
 // Returns user's recent search queries (not yet persisted)
 func (h *SearchHandler) GetSearchHistory(c *gin.Context) {
@@ -265,6 +539,97 @@ func (h *SearchHandler) ClearSearchHistory(c *gin.Context) {
 	})
 }
 
+// GetIndexStats handles GET /api/v1/search/index/stats
+// Reports how much of a project is covered by the full-text index and how far
+// it lags behind file changes
+func (h *SearchHandler) GetIndexStats(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Query("project_id"), 10, 32)
+	if err != nil || projectID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'project_id' is required"})
+		return
+	}
+	if !h.userOwnsProject(c.GetUint("user_id"), uint(projectID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	h.writeIndexStats(c, uint(projectID))
+}
+
+// GetAdminIndexStats handles GET /api/v1/admin/search/index
+// Reports index coverage and health across all projects, or one project
+// with ?project_id=
+func (h *SearchHandler) GetAdminIndexStats(c *gin.Context) {
+	projectID, _ := strconv.ParseUint(c.Query("project_id"), 10, 32)
+	h.writeIndexStats(c, uint(projectID))
+}
+
+func (h *SearchHandler) writeIndexStats(c *gin.Context, projectID uint) {
+	stats, err := h.indexer.Stats(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load index stats"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"stats":   stats,
+	})
+}
+
+// RebuildIndex handles POST /api/v1/admin/search/index/rebuild
+// Marks a project's index entries, or every entry, for reindexing
+func (h *SearchHandler) RebuildIndex(c *gin.Context) {
+	var req struct {
+		ProjectID uint `json:"project_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !h.indexer.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "The search index requires PostgreSQL"})
+		return
+	}
+	marked, err := h.indexer.Rebuild(c.Request.Context(), req.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild index"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"marked":  marked,
+	})
+}
+
+// RegisterAdminRoutes registers the search index endpoints on the admin group
+func (h *SearchHandler) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/search/index", h.GetAdminIndexStats)
+	admin.POST("/search/index/rebuild", h.RebuildIndex)
+}
+
+// userIsProjectOwner reports whether userID owns the project. Writes need
+// ownership; public projects are only readable.
+func (h *SearchHandler) userIsProjectOwner(userID, projectID uint) bool {
+	if h.db == nil {
+		return false
+	}
+	var project models.Project
+	if err := h.db.Select("id", "owner_id").First(&project, projectID).Error; err != nil {
+		return false
+	}
+	return project.OwnerID == userID
+}
+
+// username is the author name recorded on file versions
+func (h *SearchHandler) username(userID uint) string {
+	var user models.User
+	if h.db != nil {
+		h.db.Select("id", "username").Limit(1).Find(&user, userID)
+	}
+	return user.Username
+}
+
 // userOwnsProject checks if the user owns or has access to the project
 func (h *SearchHandler) userOwnsProject(userID, projectID uint) bool {
 	if h.db == nil {
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"apex-build/internal/search"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// stubOrgPermissions grants projects:read on the listed organizations
+type stubOrgPermissions map[uint]bool
+
+func (p stubOrgPermissions) HasPermission(orgID, _ uint, resource, action string) bool {
+	return resource == "projects" && action == "read" && p[orgID]
+}
+
+func TestSearchWorkspaceFiltersProjectsByAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}))
+	require.NoError(t, db.Exec(`CREATE TABLE organization_members (id INTEGER PRIMARY KEY, organization_id INTEGER, user_id INTEGER, status TEXT, deleted_at DATETIME)`).Error)
+
+	alice := models.User{Username: "alice", Email: "alice@example.com", PasswordHash: "x"}
+	bob := models.User{Username: "bob", Email: "bob@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&alice).Error)
+	require.NoError(t, db.Create(&bob).Error)
+
+	orgID, otherOrgID := uint(10), uint(11)
+	projects := []models.Project{
+		{Name: "alice-api", Language: "go", OwnerID: alice.ID},
+		{Name: "team-billing", Language: "go", OwnerID: bob.ID, OrganizationID: &orgID},
+		{Name: "bob-private", Language: "go", OwnerID: bob.ID},
+		{Name: "other-org", Language: "go", OwnerID: bob.ID, OrganizationID: &otherOrgID},
+	}
+	for i := range projects {
+		require.NoError(t, db.Create(&projects[i]).Error)
+		file := models.File{ProjectID: projects[i].ID, Path: "webhook.go", Name: "webhook.go", Type: "file", MimeType: "text/x-go",
+			Content: "func verifyWebhookSignature() {}\n"}
+		require.NoError(t, db.Create(&file).Error)
+	}
+	require.NoError(t, db.Exec(`INSERT INTO organization_members (organization_id, user_id, status) VALUES (?, ?, 'active'), (?, ?, 'active')`,
+		orgID, alice.ID, otherOrgID, alice.ID).Error)
+
+	handler := NewSearchHandler(search.NewSearchEngine(db), db)
+	handler.SetOrgPermissions(stubOrgPermissions{orgID: true})
+
+	searchAs := func(body string) (int, map[string]any) {
+		recorder := serveCommentRequest(t, handler.SearchWorkspace, http.MethodPost, "/search/workspace", nil, alice.ID, body)
+		var decoded map[string]any
+		_ = json.Unmarshal(recorder.Body.Bytes(), &decoded)
+		return recorder.Code, decoded
+	}
+	projectNames := func(payload map[string]any) []string {
+		var names []string
+		for _, p := range payload["results"].(map[string]any)["projects"].([]any) {
+			names = append(names, p.(map[string]any)["project_name"].(string))
+		}
+		return names
+	}
+
+	code, payload := searchAs(`{"query":"verifyWebhookSignature"}`)
+	require.Equal(t, http.StatusOK, code, payload)
+	require.ElementsMatch(t, []string{"alice-api", "team-billing"}, projectNames(payload))
+
+	code, payload = searchAs(`{"query":"verifyWebhookSignature","scope":"org","organization_id":10}`)
+	require.Equal(t, http.StatusOK, code, payload)
+	require.Equal(t, []string{"team-billing"}, projectNames(payload))
+
+	// A membership without projects:read grants nothing
+	code, _ = searchAs(`{"query":"verifyWebhookSignature","scope":"org","organization_id":11}`)
+	require.Equal(t, http.StatusForbidden, code)
+	code, _ = searchAs(`{"query":"x","scope":"everything"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// Two searches went through; the burst allows three more
+	for range workspaceSearchBurst - 2 {
+		code, _ = searchAs(`{"query":"verifyWebhookSignature"}`)
+		require.Equal(t, http.StatusOK, code)
+	}
+	code, _ = searchAs(`{"query":"verifyWebhookSignature"}`)
+	require.Equal(t, http.StatusTooManyRequests, code)
+}
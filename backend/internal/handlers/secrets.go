@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"apex-build/internal/mobile"
+	"apex-build/internal/pagination"
 	"apex-build/internal/secrets"
 	"apex-build/pkg/models"
 
@@ -67,23 +68,55 @@ func (h *SecretsHandler) ListSecrets(c *gin.Context) {
 		query = query.Where("project_id = ?", uint(projectID))
 	}
 
-	if err := query.Order("created_at DESC").Find(&secretsList).Error; err != nil {
+	list, err := pagination.Parse(c, secretListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := list.Apply(query).Find(&secretsList).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch secrets"})
 		return
 	}
+	secretsList, pageInfo := pagination.Page(list, secretsList, func(s secrets.Secret) (any, any) {
+		switch list.Sort.Key {
+		case "name":
+			return s.Name, s.ID
+		case "updated_at":
+			return s.UpdatedAt, s.ID
+		}
+		return s.CreatedAt, s.ID
+	})
 
 	// Convert to metadata (no values exposed)
 	metadata := make([]secrets.SecretMetadata, len(secretsList))
 	for i, s := range secretsList {
 		metadata[i] = s.ToMetadata()
 	}
+	selected, err := pagination.SelectFields(metadata, list.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to select fields"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"secrets": metadata,
-		"count":   len(metadata),
+		"secrets":   selected,
+		"count":     len(metadata),
+		"page_info": pageInfo,
 	})
 }
 
+// secretListSpec is the pagination spec for secret listings. Without a limit
+// every secret is returned.
+var secretListSpec = pagination.Spec{
+	MaxLimit: 100,
+	Sorts: []pagination.Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "name", Column: "name"},
+		{Key: "updated_at", Column: "updated_at", Desc: true},
+	},
+}
+
 // CreateSecret creates a new encrypted secret
 func (h *SecretsHandler) CreateSecret(c *gin.Context) {
 	userID := c.GetUint("user_id")
@@ -225,6 +258,7 @@ func (h *SecretsHandler) UpdateSecret(c *gin.Context) {
 		secret.EncryptedValue = encryptedValue
 		secret.Salt = salt
 		secret.KeyFingerprint = fingerprint
+		secret.ClearPlaceholder()
 	}
 	secret.UpdatedAt = time.Now()
 
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	secretstore "apex-build/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateSecretValueClearsPlaceholder(t *testing.T) {
+	handler, userID, project, gormDB := newMobileSecretHandlerTest(t)
+	encrypted, salt, fingerprint, err := handler.manager.Encrypt(userID, "")
+	require.NoError(t, err)
+	secret := secretstore.Secret{
+		UserID:         userID,
+		ProjectID:      &project.ID,
+		Name:           "STRIPE_KEY",
+		Description:    "Payments " + secretstore.PlaceholderNote,
+		Type:           secretstore.SecretTypeEnvironment,
+		IsPlaceholder:  true,
+		EncryptedValue: encrypted,
+		Salt:           salt,
+		KeyFingerprint: fingerprint,
+	}
+	require.NoError(t, gormDB.Create(&secret).Error)
+
+	update := func(body string) {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/secrets/%d", secret.ID), strings.NewReader(body))
+		context.Request.Header.Set("Content-Type", "application/json")
+		context.Params = gin.Params{{Key: "id", Value: fmt.Sprint(secret.ID)}}
+		context.Set("user_id", userID)
+		handler.UpdateSecret(context)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+
+	// Editing only the description keeps it a placeholder
+	update(`{"description":"Stripe ` + secretstore.PlaceholderNote + `"}`)
+	var stored secretstore.Secret
+	require.NoError(t, gormDB.First(&stored, secret.ID).Error)
+	require.True(t, stored.IsPlaceholder)
+
+	update(`{"value":"sk_live_123"}`)
+	require.NoError(t, gormDB.First(&stored, secret.ID).Error)
+	require.False(t, stored.IsPlaceholder)
+	require.Equal(t, "Stripe", stored.Description)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/supportbundles"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SupportBundleHandler serves redacted diagnostics bundles for failed builds
+// and deployments, and their download by support token.
+type SupportBundleHandler struct {
+	service *supportbundles.Service
+}
+
+// NewSupportBundleHandler creates a new SupportBundleHandler.
+func NewSupportBundleHandler(service *supportbundles.Service) *SupportBundleHandler {
+	return &SupportBundleHandler{service: service}
+}
+
+// CreateSupportBundle assembles a bundle and returns its support token once.
+// POST /support-bundles
+func (h *SupportBundleHandler) CreateSupportBundle(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	var in supportbundles.CreateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request format"})
+		return
+	}
+	bundle, token, err := h.service.Create(c.Request.Context(), userID, in)
+	if err != nil {
+		h.respondSupportBundleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{
+		"bundle":     bundle,
+		"token":      token,
+		"share_path": "/api/v1/support/bundles/" + token,
+	}})
+}
+
+// ListSupportBundles returns the caller's bundles, newest first.
+// GET /support-bundles
+func (h *SupportBundleHandler) ListSupportBundles(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+	bundles, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		h.respondSupportBundleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"bundles": bundles}})
+}
+
+// DownloadSupportBundle streams one of the caller's bundle archives.
+// GET /support-bundles/:id/download
+func (h *SupportBundleHandler) DownloadSupportBundle(c *gin.Context) {
+	userID, bundleID, ok := h.parseSupportBundleRequest(c)
+	if !ok {
+		return
+	}
+	bundle, err := h.service.Download(c.Request.Context(), userID, bundleID)
+	if err != nil {
+		h.respondSupportBundleError(c, err)
+		return
+	}
+	h.sendArchive(c, bundle)
+}
+
+// RevokeSupportBundle stops the bundle's support token from working.
+// DELETE /support-bundles/:id
+func (h *SupportBundleHandler) RevokeSupportBundle(c *gin.Context) {
+	userID, bundleID, ok := h.parseSupportBundleRequest(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Revoke(c.Request.Context(), userID, bundleID); err != nil {
+		h.respondSupportBundleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DownloadSharedSupportBundle streams a bundle to anyone holding its live
+// support token; no account access is involved.
+// GET /support/bundles/:token
+func (h *SupportBundleHandler) DownloadSharedSupportBundle(c *gin.Context) {
+	bundle, err := h.service.OpenByToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.respondSupportBundleError(c, err)
+		return
+	}
+	h.sendArchive(c, bundle)
+}
+
+// RegisterRoutes registers the bundle management endpoints.
+func (h *SupportBundleHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	sb := rg.Group("/support-bundles")
+	{
+		sb.POST("", h.CreateSupportBundle)
+		sb.GET("", h.ListSupportBundles)
+		sb.GET("/:id/download", h.DownloadSupportBundle)
+		sb.DELETE("/:id", h.RevokeSupportBundle)
+	}
+}
+
+// RegisterPublicRoutes registers the token download endpoint.
+func (h *SupportBundleHandler) RegisterPublicRoutes(rg *gin.RouterGroup) {
+	rg.GET("/support/bundles/:token", h.DownloadSharedSupportBundle)
+}
+
+func (h *SupportBundleHandler) sendArchive(c *gin.Context, bundle *supportbundles.Bundle) {
+	c.Header("Content-Disposition", `attachment; filename="`+bundle.FileName()+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", bundle.Archive)
+}
+
+func (h *SupportBundleHandler) parseSupportBundleRequest(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid bundle id"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func (h *SupportBundleHandler) respondSupportBundleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, supportbundles.ErrNotFound), errors.Is(err, supportbundles.ErrInvalidToken):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, supportbundles.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, supportbundles.ErrNotEligible):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Support bundle operation failed"})
+	}
+}
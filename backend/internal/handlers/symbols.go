@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/symbols"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SymbolHandler serves the parsed symbol table of a project: symbol search
+// for go-to-symbol and the outline of a single file.
+type SymbolHandler struct {
+	service *symbols.Service
+	access  collaboration.AccessResolver
+}
+
+// NewSymbolHandler creates a new SymbolHandler. Anyone with access to the
+// project may read its symbols.
+func NewSymbolHandler(service *symbols.Service, access collaboration.AccessResolver) *SymbolHandler {
+	return &SymbolHandler{service: service, access: access}
+}
+
+// RegisterRoutes mounts symbol routes on the protected API group.
+func (h *SymbolHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/symbols", h.SearchSymbols)
+	rg.GET("/projects/:id/symbols/outline", h.GetOutline)
+}
+
+// SearchSymbols finds declarations by name across the project, best
+// matches first. An empty q lists symbols, exported ones first.
+// GET /projects/:id/symbols?q=handle&kind=function,method&limit=50
+func (h *SymbolHandler) SearchSymbols(c *gin.Context) {
+	projectID, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	query := symbols.Query{ProjectID: projectID, Text: c.Query("q")}
+	query.Limit, _ = strconv.Atoi(c.Query("limit"))
+	if raw := c.Query("kind"); raw != "" {
+		for _, kind := range strings.Split(raw, ",") {
+			if kind = strings.TrimSpace(kind); kind != "" {
+				query.Kinds = append(query.Kinds, kind)
+			}
+		}
+	}
+	if raw := c.Query("file_id"); raw != "" {
+		fileID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid file id"})
+			return
+		}
+		query.FileID = uint(fileID)
+	}
+
+	results, err := h.service.Search(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to search symbols"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"symbols": results, "count": len(results)}})
+}
+
+// GetOutline returns a file's symbols nested by class, type or module.
+// GET /projects/:id/symbols/outline?path=src/app.ts
+func (h *SymbolHandler) GetOutline(c *gin.Context) {
+	projectID, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	path := strings.TrimSpace(c.Query("path"))
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "path is required"})
+		return
+	}
+
+	outline, err := h.service.Outline(c.Request.Context(), projectID, path)
+	if err != nil {
+		if errors.Is(err, symbols.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load outline"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"path":     path,
+		"language": symbols.LanguageForPath(path),
+		"outline":  outline,
+	}})
+}
+
+// authorize checks the caller may read the project named by :id
+func (h *SymbolHandler) authorize(c *gin.Context) (uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, false
+	}
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid project id"})
+		return 0, false
+	}
+	if _, err := h.access(userID, uint(projectID)); err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Project not found"})
+		case errors.Is(err, collaboration.ErrProjectAccessDenied):
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check project access"})
+		}
+		return 0, false
+	}
+	return uint(projectID), true
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"apex-build/internal/trash"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectCacheInvalidator drops cached project listings. Implemented by
+// OptimizedHandler.
+type ProjectCacheInvalidator interface {
+	InvalidateProjectCache(ctx context.Context, userID, projectID uint)
+}
+
+// TrashHandler serves the trash/restore API for soft-deleted projects and files.
+type TrashHandler struct {
+	service    *trash.Service
+	invalidate ProjectCacheInvalidator
+}
+
+// NewTrashHandler creates a new TrashHandler.
+func NewTrashHandler(service *trash.Service) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+// SetProjectCacheInvalidator wires the project cache so restored projects
+// reappear in listings immediately.
+func (h *TrashHandler) SetProjectCacheInvalidator(inv ProjectCacheInvalidator) {
+	h.invalidate = inv
+}
+
+// ListTrash returns the authenticated user's trashed projects and files.
+// GET /trash
+func (h *TrashHandler) ListTrash(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	items, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items":          items,
+			"retention_days": int(trash.RetentionPeriod.Hours() / 24),
+		},
+	})
+}
+
+// RestoreProject restores a trashed project.
+// POST /trash/projects/:id/restore
+func (h *TrashHandler) RestoreProject(c *gin.Context) {
+	userID, id, ok := h.parseTrashRequest(c)
+	if !ok {
+		return
+	}
+
+	project, err := h.service.RestoreProject(c.Request.Context(), userID, id)
+	if err != nil {
+		h.respondTrashError(c, err)
+		return
+	}
+	if h.invalidate != nil {
+		h.invalidate.InvalidateProjectCache(c.Request.Context(), userID, project.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"project": project}})
+}
+
+// RestoreFile restores a trashed file into its project.
+// POST /trash/files/:id/restore
+func (h *TrashHandler) RestoreFile(c *gin.Context) {
+	userID, id, ok := h.parseTrashRequest(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.service.RestoreFile(c.Request.Context(), userID, id)
+	if err != nil {
+		h.respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"file": file}})
+}
+
+// PurgeProject permanently deletes a trashed project.
+// DELETE /trash/projects/:id
+func (h *TrashHandler) PurgeProject(c *gin.Context) {
+	userID, id, ok := h.parseTrashRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.PurgeProject(c.Request.Context(), userID, id); err != nil {
+		h.respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// PurgeFile permanently deletes a trashed file.
+// DELETE /trash/files/:id
+func (h *TrashHandler) PurgeFile(c *gin.Context) {
+	userID, id, ok := h.parseTrashRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.PurgeFile(c.Request.Context(), userID, id); err != nil {
+		h.respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// EmptyTrash permanently deletes everything in the user's trash.
+// DELETE /trash
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return
+	}
+
+	purged, err := h.service.Empty(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to empty trash"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"purged": purged}})
+}
+
+func (h *TrashHandler) parseTrashRequest(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "User not authenticated"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid id"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func (h *TrashHandler) respondTrashError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, trash.ErrPathConflict), errors.Is(err, trash.ErrProjectTrashed):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Trash operation failed"})
+	}
+}
+
+// RegisterRoutes registers the trash endpoints. restoreProjectGuard runs
+// before project restore so restoring counts against the project quota the
+// same way creating a project does.
+func (h *TrashHandler) RegisterRoutes(rg *gin.RouterGroup, restoreProjectGuard ...gin.HandlerFunc) {
+	tg := rg.Group("/trash")
+	{
+		tg.GET("", h.ListTrash)
+		tg.DELETE("", h.EmptyTrash)
+		tg.POST("/projects/:id/restore", append(restoreProjectGuard, h.RestoreProject)...)
+		tg.DELETE("/projects/:id", h.PurgeProject)
+		tg.POST("/files/:id/restore", h.RestoreFile)
+		tg.DELETE("/files/:id", h.PurgeFile)
+	}
+}
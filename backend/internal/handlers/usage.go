@@ -6,6 +6,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"apex-build/internal/middleware"
 	"apex-build/internal/payments"
@@ -18,8 +19,15 @@ import (
 
 // UsageHandlers contains all usage-related HTTP handlers
 type UsageHandlers struct {
-	db      *gorm.DB
-	tracker *usage.Tracker
+	db       *gorm.DB
+	tracker  *usage.Tracker
+	orgPerms UsageOrgPermissions
+}
+
+// UsageOrgPermissions checks access to an organization's usage history.
+// *enterprise.RBACService satisfies it.
+type UsageOrgPermissions interface {
+	HasPermission(orgID, userID uint, resource, action string) bool
 }
 
 // NewUsageHandlers creates a new usage handlers instance
@@ -30,6 +38,12 @@ func NewUsageHandlers(db *gorm.DB, tracker *usage.Tracker) *UsageHandlers {
 	}
 }
 
+// SetOrgPermissions enables organization usage history for members with
+// organization:read.
+func (h *UsageHandlers) SetOrgPermissions(perms UsageOrgPermissions) {
+	h.orgPerms = perms
+}
+
 // GetTracker returns the usage tracker for middleware integration
 func (h *UsageHandlers) GetTracker() *usage.Tracker {
 	return h.tracker
@@ -118,6 +132,7 @@ func (h *UsageHandlers) GetCurrentUsage(c *gin.Context) {
 				},
 			},
 			"warnings":  getUsageWarnings(currentUsage, unlimited),
+			"overrides": currentUsage.Overrides,
 			"cached_at": currentUsage.CachedAt,
 		},
 	}
@@ -125,7 +140,9 @@ func (h *UsageHandlers) GetCurrentUsage(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetUsageHistory returns historical usage data
+// GetUsageHistory returns historical usage data: daily and monthly totals
+// plus per-period snapshots (the current period first, then up to 12 closed
+// periods). Pass organization_id for an organization's period history.
 // GET /api/v1/usage/history
 func (h *UsageHandlers) GetUsageHistory(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -145,6 +162,17 @@ func (h *UsageHandlers) GetUsageHistory(c *gin.Context) {
 			days = d
 		}
 	}
+	months := usage.HistoryMonths
+	if monthsParam := c.Query("months"); monthsParam != "" {
+		if m, err := strconv.Atoi(monthsParam); err == nil && m > 0 && m <= 36 {
+			months = m
+		}
+	}
+
+	if orgParam := c.Query("organization_id"); orgParam != "" {
+		h.getOrgUsageHistory(c, userID, orgParam, months)
+		return
+	}
 
 	history, err := h.tracker.GetUsageHistory(c.Request.Context(), userID, days)
 	if err != nil {
@@ -156,13 +184,71 @@ func (h *UsageHandlers) GetUsageHistory(c *gin.Context) {
 		return
 	}
 
+	var user models.User
+	plan := usage.PlanFree
+	if err := h.db.Select("subscription_type", "subscription_status").First(&user, userID).Error; err == nil && user.SubscriptionType != "" {
+		plan = usage.PlanType(user.SubscriptionType)
+		if user.SubscriptionStatus == "past_due" || user.SubscriptionStatus == "canceled" {
+			plan = usage.PlanFree
+		}
+	}
+	periods, err := h.tracker.GetUserPeriodHistory(c.Request.Context(), userID, plan, months, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get usage history",
+			"code":    "HISTORY_ERROR",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
 			"user_id": userID,
 			"days":    days,
+			"months":  months,
 			"daily":   history.Daily,
 			"monthly": history.Monthly,
+			"periods": periods,
+		},
+	})
+}
+
+func (h *UsageHandlers) getOrgUsageHistory(c *gin.Context, userID uint, orgParam string, months int) {
+	orgID, err := strconv.ParseUint(orgParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid organization ID",
+			"code":    "INVALID_REQUEST",
+		})
+		return
+	}
+	if h.orgPerms == nil || !h.orgPerms.HasPermission(uint(orgID), userID, "organization", "read") {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Organization usage is not available",
+			"code":    "FORBIDDEN",
+		})
+		return
+	}
+
+	periods, err := h.tracker.GetPeriodHistory(c.Request.Context(), usage.SubjectOrganization, uint(orgID), months)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get usage history",
+			"code":    "HISTORY_ERROR",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"organization_id": orgID,
+			"months":          months,
+			"periods":         periods,
 		},
 	})
 }
@@ -228,6 +314,60 @@ func usagePricingFromPaymentPlans() gin.H {
 	return pricing
 }
 
+// GetStorageBreakdown shows which projects consume the user's storage quota
+// GET /api/v1/usage/storage
+func (h *UsageHandlers) GetStorageBreakdown(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+			"code":    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Select("subscription_type").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get user information",
+			"code":    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	plan := usage.PlanType(user.SubscriptionType)
+	if plan == "" {
+		plan = usage.PlanFree
+	}
+
+	breakdown, err := h.tracker.GetStorageBreakdown(c.Request.Context(), userID, plan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get storage breakdown",
+			"code":    "USAGE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"plan":            string(breakdown.Plan),
+			"total":           breakdown.TotalBytes,
+			"limit":           breakdown.LimitBytes,
+			"percentage":      calculatePercentage(breakdown.TotalBytes, breakdown.LimitBytes),
+			"total_formatted": formatBytes(breakdown.TotalBytes),
+			"limit_formatted": formatBytes(breakdown.LimitBytes),
+			"trash_weight":    breakdown.TrashWeight,
+			"projects":        breakdown.Projects,
+			"reconciled_at":   breakdown.ReconciledAt,
+		},
+	})
+}
+
 // RefreshUsage forces a refresh of cached usage data
 // POST /api/v1/usage/refresh
 func (h *UsageHandlers) RefreshUsage(c *gin.Context) {
@@ -263,6 +403,7 @@ func (h *UsageHandlers) RegisterUsageRoutes(group *gin.RouterGroup) {
 		usageGroup.GET("/current", h.GetCurrentUsage)
 		usageGroup.GET("/history", h.GetUsageHistory)
 		usageGroup.GET("/limits", h.GetLimits)
+		usageGroup.GET("/storage", h.GetStorageBreakdown)
 		usageGroup.POST("/refresh", h.RefreshUsage)
 	}
 }
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"apex-build/internal/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListQuotaOverrides returns quota overrides, newest first.
+// GET /admin/quota-overrides?subject_type=user&subject_id=42&status=active
+func (h *UsageHandlers) ListQuotaOverrides(c *gin.Context) {
+	filter := usage.OverrideFilter{
+		SubjectType: c.Query("subject_type"),
+		Status:      c.Query("status"),
+	}
+	if raw := c.Query("subject_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid subject_id"})
+			return
+		}
+		filter.SubjectID = uint(id)
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	overrides, err := h.tracker.ListOverrides(c.Request.Context(), filter)
+	if errors.Is(err, usage.ErrInvalidOverride) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load quota overrides"})
+		return
+	}
+	now := time.Now().UTC()
+	items := make([]gin.H, 0, len(overrides))
+	for i := range overrides {
+		items = append(items, quotaOverrideResponse(&overrides[i], now))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"overrides": items}})
+}
+
+// CreateQuotaOverride grants a user or organization a temporary boost or a
+// replacement limit for one usage type.
+// POST /admin/quota-overrides
+func (h *UsageHandlers) CreateQuotaOverride(c *gin.Context) {
+	var req struct {
+		SubjectType string     `json:"subject_type" binding:"required"`
+		SubjectID   uint       `json:"subject_id" binding:"required"`
+		UsageType   string     `json:"usage_type" binding:"required"`
+		Mode        string     `json:"mode" binding:"required"`
+		Amount      int64      `json:"amount"`
+		Reason      string     `json:"reason" binding:"required"`
+		StartsAt    *time.Time `json:"starts_at"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "subject_type, subject_id, usage_type, mode and reason are required"})
+		return
+	}
+	override, err := h.tracker.CreateOverride(c.Request.Context(), c.GetUint("user_id"), usage.OverrideInput{
+		SubjectType: req.SubjectType,
+		SubjectID:   req.SubjectID,
+		Type:        usage.UsageType(req.UsageType),
+		Mode:        req.Mode,
+		Amount:      req.Amount,
+		Reason:      req.Reason,
+		StartsAt:    req.StartsAt,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	switch {
+	case errors.Is(err, usage.ErrInvalidOverride):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	case errors.Is(err, usage.ErrOverrideSubjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create quota override"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": quotaOverrideResponse(override, time.Now().UTC())})
+}
+
+// RevokeQuotaOverride ends an override early; the plan limit applies again
+// on the subject's next request.
+// DELETE /admin/quota-overrides/:id
+func (h *UsageHandlers) RevokeQuotaOverride(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid override id"})
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	// The body is optional
+	_ = c.ShouldBindJSON(&req)
+	override, err := h.tracker.RevokeOverride(c.Request.Context(), c.GetUint("user_id"), uint(id), req.Note)
+	switch {
+	case errors.Is(err, usage.ErrOverrideNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	case errors.Is(err, usage.ErrInvalidOverride):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	case errors.Is(err, usage.ErrOverrideClosed):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to revoke quota override"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": quotaOverrideResponse(override, time.Now().UTC())})
+}
+
+func quotaOverrideResponse(o *usage.QuotaOverride, now time.Time) gin.H {
+	return gin.H{
+		"id":           o.ID,
+		"subject_type": o.SubjectType,
+		"subject_id":   o.SubjectID,
+		"usage_type":   o.Type,
+		"mode":         o.Mode,
+		"amount":       o.Amount,
+		"reason":       o.Reason,
+		"status":       o.Status(now),
+		"starts_at":    o.StartsAt,
+		"expires_at":   o.ExpiresAt,
+		"created_by":   o.CreatedBy,
+		"created_at":   o.CreatedAt,
+		"expired_at":   o.ExpiredAt,
+		"revoked_at":   o.RevokedAt,
+		"revoked_by":   o.RevokedBy,
+		"revoke_note":  o.RevokeNote,
+	}
+}
+
+// RegisterAdminRoutes registers quota override management on the admin group
+func (h *UsageHandlers) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/quota-overrides", h.ListQuotaOverrides)
+	admin.POST("/quota-overrides", h.CreateQuotaOverride)
+	admin.DELETE("/quota-overrides/:id", h.RevokeQuotaOverride)
+}
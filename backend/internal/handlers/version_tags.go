@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/collaboration"
+	"apex-build/internal/middleware"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// versionTagPattern accepts names like "v1.0-submitted" or "release_2"
+var versionTagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,99}$`)
+
+// VersionTag is a tagged file version, for quick navigation
+type VersionTag struct {
+	VersionID   uint       `json:"version_id"`
+	FileID      uint       `json:"file_id"`
+	FilePath    string     `json:"file_path"`
+	Version     int        `json:"version"`
+	Tag         string     `json:"tag"`
+	IsProtected bool       `json:"is_protected"`
+	TaggedByID  *uint      `json:"tagged_by_id,omitempty"`
+	TaggedAt    *time.Time `json:"tagged_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TagVersionRequest names a version. An empty tag removes it.
+type TagVersionRequest struct {
+	Tag       string `json:"tag"`
+	Protected *bool  `json:"protected"` // defaults to true for new tags
+}
+
+// TagVersion tags a file version, or removes its tag
+// PUT /versions/:versionId/tag
+func (vh *VersionHandler) TagVersion(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+
+	versionID, err := strconv.ParseUint(c.Param("versionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid version ID",
+			Code:    "INVALID_VERSION_ID",
+		})
+		return
+	}
+
+	var req TagVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid request",
+			Code:    "INVALID_REQUEST",
+		})
+		return
+	}
+	req.Tag = strings.TrimSpace(req.Tag)
+	if req.Tag != "" && !versionTagPattern.MatchString(req.Tag) {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Tags are up to 100 letters, digits, dots, dashes, underscores or plus signs",
+			Code:    "INVALID_TAG",
+		})
+		return
+	}
+	protected := req.Tag != "" && (req.Protected == nil || *req.Protected)
+
+	var version models.FileVersion
+	if err := vh.DB.First(&version, uint(versionID)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, StandardResponse{
+				Success: false,
+				Error:   "Version not found",
+				Code:    "VERSION_NOT_FOUND",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Database error",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	permission, ok := vh.requirePermission(c, userID, version.ProjectID, canEditProject)
+	if !ok {
+		return
+	}
+	// Editors may tag, but lifting protection takes an admin
+	if version.IsProtected && !protected && !canAdminProject(permission) {
+		c.JSON(http.StatusForbidden, StandardResponse{
+			Success: false,
+			Error:   "Only project admins can remove or unprotect a protected tag",
+			Code:    "VERSION_PROTECTED",
+		})
+		return
+	}
+
+	if req.Tag != "" {
+		var clash int64
+		vh.DB.Model(&models.FileVersion{}).
+			Where("file_id = ? AND tag = ? AND id <> ?", version.FileID, req.Tag, version.ID).
+			Count(&clash)
+		if clash > 0 {
+			c.JSON(http.StatusConflict, StandardResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Another version of this file is tagged %q", req.Tag),
+				Code:    "TAG_EXISTS",
+			})
+			return
+		}
+		now := time.Now()
+		version.Tag, version.IsProtected, version.TaggedByID, version.TaggedAt = req.Tag, protected, &userID, &now
+	} else {
+		version.Tag, version.IsProtected, version.TaggedByID, version.TaggedAt = "", false, nil, nil
+	}
+
+	if err := vh.DB.Model(&version).Select("tag", "is_protected", "tagged_by_id", "tagged_at").Updates(&version).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to tag version",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Data:    versionTag(version),
+	})
+}
+
+// GetProjectVersionTags lists every tagged file version in a project, newest first
+// GET /versions/project/:projectId/tags
+func (vh *VersionHandler) GetProjectVersionTags(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Error:   "User not authenticated",
+			Code:    "NOT_AUTHENTICATED",
+		})
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Error:   "Invalid project ID",
+			Code:    "INVALID_PROJECT_ID",
+		})
+		return
+	}
+	if _, ok := vh.requirePermission(c, userID, uint(projectID), nil); !ok {
+		return
+	}
+
+	tags, err := vh.loadVersionTags("project_id = ?", uint(projectID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to fetch tags",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Data:    gin.H{"tags": tags},
+	})
+}
+
+func (vh *VersionHandler) loadVersionTags(where string, id uint) ([]VersionTag, error) {
+	var versions []models.FileVersion
+	if err := vh.DB.Select("id", "file_id", "file_path", "version", "tag", "is_protected", "tagged_by_id", "tagged_at", "created_at").
+		Where(where, id).Where("tag <> ''").
+		Order("created_at DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	tags := make([]VersionTag, len(versions))
+	for i, v := range versions {
+		tags[i] = versionTag(v)
+	}
+	return tags, nil
+}
+
+func versionTag(v models.FileVersion) VersionTag {
+	return VersionTag{
+		VersionID:   v.ID,
+		FileID:      v.FileID,
+		FilePath:    v.FilePath,
+		Version:     v.Version,
+		Tag:         v.Tag,
+		IsProtected: v.IsProtected,
+		TaggedByID:  v.TaggedByID,
+		TaggedAt:    v.TaggedAt,
+		CreatedAt:   v.CreatedAt,
+	}
+}
+
+// projectPermission resolves the caller's permission on a project. Without
+// an access resolver only the owner (and anyone, read-only, on public
+// projects) has access.
+func (vh *VersionHandler) projectPermission(userID, projectID uint) (collaboration.PermissionLevel, error) {
+	if vh.Access != nil {
+		access, err := vh.Access(userID, projectID)
+		if err != nil {
+			return "", err
+		}
+		return access.Permission, nil
+	}
+	var project models.Project
+	if err := vh.DB.Select("id", "owner_id", "is_public").First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", collaboration.ErrProjectNotFound
+		}
+		return "", err
+	}
+	switch {
+	case project.OwnerID == userID:
+		return collaboration.PermissionOwner, nil
+	case project.IsPublic:
+		return collaboration.PermissionViewer, nil
+	}
+	return "", collaboration.ErrProjectAccessDenied
+}
+
+// requirePermission writes the error response and returns false unless the
+// caller has access to the project and, when allowed is set, it accepts
+// their permission
+func (vh *VersionHandler) requirePermission(c *gin.Context, userID, projectID uint, allowed func(collaboration.PermissionLevel) bool) (collaboration.PermissionLevel, bool) {
+	permission, err := vh.projectPermission(userID, projectID)
+	switch {
+	case errors.Is(err, collaboration.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Error:   "Project not found",
+			Code:    "PROJECT_NOT_FOUND",
+		})
+		return "", false
+	case errors.Is(err, collaboration.ErrProjectAccessDenied):
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Database error",
+			Code:    "DATABASE_ERROR",
+		})
+		return "", false
+	}
+	if err != nil || (allowed != nil && !allowed(permission)) {
+		c.JSON(http.StatusForbidden, StandardResponse{
+			Success: false,
+			Error:   "Access denied",
+			Code:    "ACCESS_DENIED",
+		})
+		return "", false
+	}
+	return permission, true
+}
+
+func canEditProject(permission collaboration.PermissionLevel) bool {
+	switch permission {
+	case collaboration.PermissionOwner, collaboration.PermissionAdmin, collaboration.PermissionEditor:
+		return true
+	}
+	return false
+}
+
+func canAdminProject(permission collaboration.PermissionLevel) bool {
+	return permission == collaboration.PermissionOwner || permission == collaboration.PermissionAdmin
+}
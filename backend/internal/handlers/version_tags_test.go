@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"apex-build/internal/collaboration"
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtectedVersionTagsNeedProjectAdmin(t *testing.T) {
+	comments, db, owner, file := newCommentsTestFixture(t)
+	editor := models.User{Username: "editor", Email: "editor@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&editor).Error)
+
+	handler := NewVersionHandler(comments.DB)
+	handler.Access = func(userID, projectID uint) (*collaboration.ProjectAccess, error) {
+		access := &collaboration.ProjectAccess{ProjectID: projectID, Permission: collaboration.PermissionViewer}
+		switch userID {
+		case owner.ID:
+			access.Permission = collaboration.PermissionOwner
+		case editor.ID:
+			access.Permission = collaboration.PermissionEditor
+		}
+		return access, nil
+	}
+
+	first := CreateFileVersion(db, &file, owner.ID, "owner", "create", "")
+	file.Content += "// v2\n"
+	second := CreateFileVersion(db, &file, owner.ID, "owner", "edit", "")
+
+	versionRequest := func(h gin.HandlerFunc, method, target string, versionID, userID uint, body string) int {
+		params := gin.Params{{Key: "versionId", Value: fmt.Sprint(versionID)}}
+		return serveCommentRequest(t, h, method, target, params, userID, body).Code
+	}
+	tag := func(versionID, userID uint, body string) int {
+		return versionRequest(handler.TagVersion, http.MethodPut, "/versions/x/tag", versionID, userID, body)
+	}
+
+	require.Equal(t, http.StatusBadRequest, tag(first, editor.ID, `{"tag":"v1.0 submitted"}`))
+	require.Equal(t, http.StatusOK, tag(first, editor.ID, `{"tag":"v1.0-submitted"}`))
+	require.Equal(t, http.StatusConflict, tag(second, editor.ID, `{"tag":"v1.0-submitted"}`))
+	require.Equal(t, http.StatusOK, tag(second, editor.ID, `{"tag":"draft","protected":false}`))
+
+	// Editors can neither unprotect, restore nor delete the protected version
+	require.Equal(t, http.StatusForbidden, tag(first, editor.ID, `{"tag":""}`))
+	require.Equal(t, http.StatusForbidden, versionRequest(handler.RestoreVersion, http.MethodPost, "/versions/x/restore", first, editor.ID, ""))
+	require.Equal(t, http.StatusForbidden, versionRequest(handler.DeleteVersion, http.MethodDelete, "/versions/x", first, editor.ID, ""))
+	require.Equal(t, http.StatusOK, versionRequest(handler.RestoreVersion, http.MethodPost, "/versions/x/restore", second, editor.ID, ""))
+	require.Equal(t, http.StatusOK, versionRequest(handler.RestoreVersion, http.MethodPost, "/versions/x/restore", first, owner.ID, ""))
+
+	projectParams := gin.Params{{Key: "projectId", Value: fmt.Sprint(file.ProjectID)}}
+	recorder := serveCommentRequest(t, handler.GetProjectVersionTags, http.MethodGet, "/versions/project/x/tags", projectParams, editor.ID, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var listed struct {
+		Data struct {
+			Tags []VersionTag `json:"tags"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listed))
+	require.Len(t, listed.Data.Tags, 2)
+	byTag := map[string]VersionTag{}
+	for _, tag := range listed.Data.Tags {
+		byTag[tag.Tag] = tag
+	}
+	require.True(t, byTag["v1.0-submitted"].IsProtected)
+	require.Equal(t, first, byTag["v1.0-submitted"].VersionID)
+	require.False(t, byTag["draft"].IsProtected)
+
+	require.Equal(t, http.StatusOK, tag(first, owner.ID, `{"tag":""}`))
+	require.Equal(t, http.StatusOK, versionRequest(handler.DeleteVersion, http.MethodDelete, "/versions/x", first, editor.ID, ""))
+}
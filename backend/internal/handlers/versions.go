@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"apex-build/internal/collaboration"
 	"apex-build/internal/middleware"
 	"apex-build/pkg/models"
 
@@ -21,6 +22,10 @@ import (
 // VersionHandler handles file version operations
 type VersionHandler struct {
 	DB *gorm.DB
+
+	// Access resolves organization collaborators; when nil only the project
+	// owner may change version history
+	Access collaboration.AccessResolver
 }
 
 // NewVersionHandler creates a new version handler
@@ -35,6 +40,7 @@ type VersionListResponse struct {
 	HasMore    bool             `json:"has_more"`
 	FileID     uint             `json:"file_id"`
 	FileName   string           `json:"file_name"`
+	Tags       []VersionTag     `json:"tags"` // every tagged version of the file
 }
 
 // VersionSummary is a lightweight version representation for lists
@@ -50,6 +56,8 @@ type VersionSummary struct {
 	LinesRemoved  int       `json:"lines_removed"`
 	Size          int64     `json:"size"`
 	IsPinned      bool      `json:"is_pinned"`
+	Tag           string    `json:"tag,omitempty"`
+	IsProtected   bool      `json:"is_protected"`
 }
 
 // DiffResponse represents the diff between two versions
@@ -88,6 +96,8 @@ func (vh *VersionHandler) RegisterVersionRoutes(rg *gin.RouterGroup) {
 		versions.GET("/:versionId/content", vh.GetVersionContent)   // Get version content
 		versions.POST("/:versionId/restore", vh.RestoreVersion)     // Restore file to version
 		versions.POST("/:versionId/pin", vh.PinVersion)             // Pin/unpin version
+		versions.PUT("/:versionId/tag", vh.TagVersion)              // Tag/untag version
+		versions.GET("/project/:projectId/tags", vh.GetProjectVersionTags) // List a project's tagged versions
 		versions.GET("/diff/:oldId/:newId", vh.GetDiff)             // Get diff between versions
 		versions.GET("/file/:fileId/diff", vh.GetFileDiff)          // Get diff with current
 		versions.DELETE("/:versionId", vh.DeleteVersion)            // Delete unpinned version
@@ -187,9 +197,21 @@ func (vh *VersionHandler) GetFileVersions(c *gin.Context) {
 			LinesRemoved:  v.LinesRemoved,
 			Size:          v.Size,
 			IsPinned:      v.IsPinned,
+			Tag:           v.Tag,
+			IsProtected:   v.IsProtected,
 		}
 	}
 
+	tags, err := vh.loadVersionTags("file_id = ?", uint(fileID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Error:   "Failed to fetch tags",
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, StandardResponse{
 		Success: true,
 		Data: VersionListResponse{
@@ -198,6 +220,7 @@ func (vh *VersionHandler) GetFileVersions(c *gin.Context) {
 			HasMore:  int64(offset+limit) < total,
 			FileID:   uint(fileID),
 			FileName: file.Name,
+			Tags:     tags,
 		},
 	})
 }
@@ -388,22 +411,16 @@ func (vh *VersionHandler) RestoreVersion(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
-	var project models.Project
-	if err := vh.DB.First(&project, version.ProjectID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, StandardResponse{
-			Success: false,
-			Error:   "Database error",
-			Code:    "DATABASE_ERROR",
-		})
+	// Editors may restore; protected versions take a project admin
+	permission, ok := vh.requirePermission(c, userID, version.ProjectID, canEditProject)
+	if !ok {
 		return
 	}
-
-	if project.OwnerID != userID {
+	if version.IsProtected && !canAdminProject(permission) {
 		c.JSON(http.StatusForbidden, StandardResponse{
 			Success: false,
-			Error:   "Access denied - only project owner can restore versions",
-			Code:    "ACCESS_DENIED",
+			Error:   "Access denied - only project admins can restore protected versions",
+			Code:    "VERSION_PROTECTED",
 		})
 		return
 	}
@@ -530,23 +547,8 @@ func (vh *VersionHandler) PinVersion(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
-	var project models.Project
-	if err := vh.DB.First(&project, version.ProjectID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, StandardResponse{
-			Success: false,
-			Error:   "Database error",
-			Code:    "DATABASE_ERROR",
-		})
-		return
-	}
-
-	if project.OwnerID != userID {
-		c.JSON(http.StatusForbidden, StandardResponse{
-			Success: false,
-			Error:   "Access denied",
-			Code:    "ACCESS_DENIED",
-		})
+	// Check edit access
+	if _, ok := vh.requirePermission(c, userID, version.ProjectID, canEditProject); !ok {
 		return
 	}
 
@@ -792,22 +794,16 @@ func (vh *VersionHandler) DeleteVersion(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
-	var project models.Project
-	if err := vh.DB.First(&project, version.ProjectID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, StandardResponse{
-			Success: false,
-			Error:   "Database error",
-			Code:    "DATABASE_ERROR",
-		})
+	// Editors may delete; protected versions take a project admin
+	permission, ok := vh.requirePermission(c, userID, version.ProjectID, canEditProject)
+	if !ok {
 		return
 	}
-
-	if project.OwnerID != userID {
+	if version.IsProtected && !canAdminProject(permission) {
 		c.JSON(http.StatusForbidden, StandardResponse{
 			Success: false,
-			Error:   "Access denied",
-			Code:    "ACCESS_DENIED",
+			Error:   "Only project admins can delete protected versions",
+			Code:    "VERSION_PROTECTED",
 		})
 		return
 	}
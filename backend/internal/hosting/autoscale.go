@@ -0,0 +1,574 @@
+// Package hosting - Request-based autoscaling for always-on deployments
+// The proxy records request rate and latency per deployment. The autoscaler
+// reads those samples and adds or removes health-checked instances between
+// the deployment's minimum and maximum; the proxy balances requests across
+// the running instances.
+package hosting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTargetRPSPerInstance is the request rate one instance is
+	// expected to handle
+	DefaultTargetRPSPerInstance = 50
+	// DefaultTargetLatencyMs is the average latency above which the
+	// autoscaler adds an instance even if the request rate is in range
+	DefaultTargetLatencyMs = 500
+
+	// EventScale is the deployment event type of a scale change
+	EventScale = "scale"
+
+	// loadFlushInterval is how often the proxy writes load samples
+	loadFlushInterval = 15 * time.Second
+	// autoscaleWindow is the load window scale decisions look at
+	autoscaleWindow = 2 * time.Minute
+	// Scaling up reacts quickly; scaling down waits for load to settle
+	scaleUpCooldown   = time.Minute
+	scaleDownCooldown = 5 * time.Minute
+	// defaultScaleDrain keeps a removed instance running until the proxy's
+	// 30-second route cache stops sending it requests
+	defaultScaleDrain = 45 * time.Second
+	// loadSampleRetention is how long load samples are kept
+	loadSampleRetention = 7 * 24 * time.Hour
+)
+
+// Scale reasons
+const (
+	ScaleReasonRequestRate = "request_rate"
+	ScaleReasonLatency     = "latency"
+	ScaleReasonLowLoad     = "low_load"
+	ScaleReasonMinimum     = "min_instances"
+	ScaleReasonMaximum     = "max_instances"
+	ScaleReasonDisabled    = "autoscale_disabled"
+)
+
+var (
+	// ErrInvalidAutoscale wraps autoscale validation failures
+	ErrInvalidAutoscale = errors.New("invalid autoscale settings")
+	// ErrAutoscaleRequiresAlwaysOn is returned when autoscaling is enabled
+	// on a deployment that may sleep
+	ErrAutoscaleRequiresAlwaysOn = errors.New("autoscaling requires an always-on deployment")
+)
+
+// DeploymentLoadSample is the traffic the proxy served for a deployment in
+// one flush interval
+type DeploymentLoadSample struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	DeploymentID string    `json:"deployment_id" gorm:"not null;index:idx_load_samples_deployment_window;type:varchar(36)"`
+	WindowStart  time.Time `json:"window_start" gorm:"not null;index:idx_load_samples_deployment_window"`
+	WindowEnd    time.Time `json:"window_end" gorm:"not null"`
+	Requests     int64     `json:"requests"`
+	Errors       int64     `json:"errors"` // 5xx responses
+	LatencyMsSum int64     `json:"latency_ms_sum"`
+	LatencyMsMax int64     `json:"latency_ms_max"`
+}
+
+// TableName specifies the table name for DeploymentLoadSample
+func (DeploymentLoadSample) TableName() string {
+	return "deployment_load_samples"
+}
+
+// AutoscaleConfig is a deployment's scaling policy
+type AutoscaleConfig struct {
+	Enabled              bool `json:"enabled"`
+	MinInstances         int  `json:"min_instances"`
+	MaxInstances         int  `json:"max_instances"`
+	TargetRPSPerInstance int  `json:"target_rps_per_instance"`
+	TargetLatencyMs      int  `json:"target_latency_ms"`
+}
+
+// LoadStats summarizes a deployment's recent traffic
+type LoadStats struct {
+	WindowSeconds     int     `json:"window_seconds"`
+	Requests          int64   `json:"requests"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	AvgLatencyMs      int64   `json:"avg_latency_ms"`
+	MaxLatencyMs      int64   `json:"max_latency_ms"`
+	ErrorRate         float64 `json:"error_rate"`
+}
+
+// LoadPoint is one minute of a deployment's traffic
+type LoadPoint struct {
+	Minute       time.Time `json:"minute"`
+	Requests     int64     `json:"requests"`
+	Errors       int64     `json:"errors"`
+	AvgLatencyMs int64     `json:"avg_latency_ms"`
+	MaxLatencyMs int64     `json:"max_latency_ms"`
+}
+
+// ScaleDetails is the metadata of a scale event
+type ScaleDetails struct {
+	From              int     `json:"from"`
+	To                int     `json:"to"`
+	Reason            string  `json:"reason"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	AvgLatencyMs      int64   `json:"avg_latency_ms"`
+	Error             string  `json:"error,omitempty"`
+}
+
+// SetScaleDrain sets how long a removed instance keeps running after the
+// instance count drops
+func (s *HostingService) SetScaleDrain(drain time.Duration) {
+	s.scaleDrain = drain
+}
+
+// autoscaleConfig returns the deployment's policy with defaults applied
+func autoscaleConfig(d *NativeDeployment) AutoscaleConfig {
+	config := AutoscaleConfig{
+		Enabled:              d.AutoScale,
+		MinInstances:         d.MinInstances,
+		MaxInstances:         d.MaxInstances,
+		TargetRPSPerInstance: d.TargetRPSPerInstance,
+		TargetLatencyMs:      d.TargetLatencyMs,
+	}
+	if config.MinInstances < 1 {
+		config.MinInstances = 1
+	}
+	if config.MaxInstances < config.MinInstances {
+		config.MaxInstances = config.MinInstances
+	}
+	if config.TargetRPSPerInstance <= 0 {
+		config.TargetRPSPerInstance = DefaultTargetRPSPerInstance
+	}
+	if config.TargetLatencyMs <= 0 {
+		config.TargetLatencyMs = DefaultTargetLatencyMs
+	}
+	return config
+}
+
+// AutoscaleConfigFor returns a deployment's scaling policy with defaults applied
+func AutoscaleConfigFor(d *NativeDeployment) AutoscaleConfig {
+	return autoscaleConfig(d)
+}
+
+// UpdateAutoscale validates and stores a deployment's scaling policy.
+// maxAllowed is the instance cap of the owner's plan. Turning autoscaling
+// off scales the deployment back to its minimum.
+func (s *HostingService) UpdateAutoscale(deploymentID string, config AutoscaleConfig, maxAllowed int) (*NativeDeployment, error) {
+	deployment, err := s.GetDeployment(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if config.Enabled && !deployment.AlwaysOn {
+		return nil, ErrAutoscaleRequiresAlwaysOn
+	}
+	if config.MinInstances == 0 {
+		config.MinInstances = 1
+	}
+	if config.MaxInstances == 0 {
+		config.MaxInstances = config.MinInstances
+	}
+	if config.MinInstances < 1 || config.MaxInstances < config.MinInstances {
+		return nil, fmt.Errorf("%w: need 1 <= min_instances <= max_instances", ErrInvalidAutoscale)
+	}
+	if config.MaxInstances > maxAllowed {
+		return nil, fmt.Errorf("%w: your plan allows up to %d instances", ErrInvalidAutoscale, maxAllowed)
+	}
+	if config.TargetRPSPerInstance < 0 || config.TargetRPSPerInstance > 10000 {
+		return nil, fmt.Errorf("%w: target_rps_per_instance must be between 1 and 10000", ErrInvalidAutoscale)
+	}
+	if config.TargetLatencyMs < 0 || config.TargetLatencyMs > 60000 {
+		return nil, fmt.Errorf("%w: target_latency_ms must be between 1 and 60000", ErrInvalidAutoscale)
+	}
+
+	deployment.AutoScale = config.Enabled
+	deployment.MinInstances = config.MinInstances
+	deployment.MaxInstances = config.MaxInstances
+	deployment.TargetRPSPerInstance = config.TargetRPSPerInstance
+	deployment.TargetLatencyMs = config.TargetLatencyMs
+	if err := s.db.Model(deployment).Updates(map[string]interface{}{
+		"auto_scale":              config.Enabled,
+		"min_instances":           config.MinInstances,
+		"max_instances":           config.MaxInstances,
+		"target_rps_per_instance": config.TargetRPSPerInstance,
+		"target_latency_ms":       config.TargetLatencyMs,
+	}).Error; err != nil {
+		return nil, err
+	}
+	s.addLog(deploymentID, "info", "autoscaler", fmt.Sprintf("Autoscaling %s (%d-%d instances)",
+		map[bool]string{true: "enabled", false: "disabled"}[config.Enabled], config.MinInstances, config.MaxInstances))
+
+	// Bring the instance count inside the new bounds right away
+	if deployment.Status == StatusRunning {
+		current := instanceCount(deployment)
+		target, reason := current, ""
+		switch {
+		case !config.Enabled && current != config.MinInstances:
+			target, reason = config.MinInstances, ScaleReasonDisabled
+		case current < config.MinInstances:
+			target, reason = config.MinInstances, ScaleReasonMinimum
+		case current > config.MaxInstances:
+			target, reason = config.MaxInstances, ScaleReasonMaximum
+		}
+		if target != current {
+			go s.scaleDeployment(context.Background(), deployment.ID, target, reason, LoadStats{})
+		}
+	}
+	return deployment, nil
+}
+
+// DeploymentLoad summarizes the deployment's traffic over the window
+func (s *HostingService) DeploymentLoad(deploymentID string, window time.Duration, now time.Time) LoadStats {
+	var samples []DeploymentLoadSample
+	s.db.Where("deployment_id = ? AND window_end > ?", deploymentID, now.Add(-window)).Find(&samples)
+	return summarizeLoad(samples, window)
+}
+
+func summarizeLoad(samples []DeploymentLoadSample, window time.Duration) LoadStats {
+	stats := LoadStats{WindowSeconds: int(window.Seconds())}
+	var errs, latencySum int64
+	for _, sample := range samples {
+		stats.Requests += sample.Requests
+		errs += sample.Errors
+		latencySum += sample.LatencyMsSum
+		if sample.LatencyMsMax > stats.MaxLatencyMs {
+			stats.MaxLatencyMs = sample.LatencyMsMax
+		}
+	}
+	if stats.Requests > 0 {
+		stats.RequestsPerSecond = math.Round(float64(stats.Requests)/window.Seconds()*100) / 100
+		stats.AvgLatencyMs = latencySum / stats.Requests
+		stats.ErrorRate = math.Round(float64(errs)/float64(stats.Requests)*10000) / 10000
+	}
+	return stats
+}
+
+// LoadHistory returns the deployment's traffic per minute since the given time
+func (s *HostingService) LoadHistory(deploymentID string, since time.Time) []LoadPoint {
+	var samples []DeploymentLoadSample
+	s.db.Where("deployment_id = ? AND window_start >= ?", deploymentID, since).Order("window_start").Find(&samples)
+
+	var points []LoadPoint
+	var latencySum int64
+	for _, sample := range samples {
+		minute := sample.WindowStart.UTC().Truncate(time.Minute)
+		if len(points) == 0 || !points[len(points)-1].Minute.Equal(minute) {
+			if len(points) > 0 && points[len(points)-1].Requests > 0 {
+				points[len(points)-1].AvgLatencyMs = latencySum / points[len(points)-1].Requests
+			}
+			points = append(points, LoadPoint{Minute: minute})
+			latencySum = 0
+		}
+		point := &points[len(points)-1]
+		point.Requests += sample.Requests
+		point.Errors += sample.Errors
+		latencySum += sample.LatencyMsSum
+		if sample.LatencyMsMax > point.MaxLatencyMs {
+			point.MaxLatencyMs = sample.LatencyMsMax
+		}
+	}
+	if len(points) > 0 && points[len(points)-1].Requests > 0 {
+		points[len(points)-1].AvgLatencyMs = latencySum / points[len(points)-1].Requests
+	}
+	return points
+}
+
+// desiredInstances decides how many instances the deployment should run.
+// Scaling up jumps straight to what the request rate needs, or adds one
+// instance when latency is over target. Scaling down removes one instance
+// at a time, only while latency is healthy.
+func desiredInstances(d *NativeDeployment, load LoadStats, now time.Time) (int, string) {
+	config := autoscaleConfig(d)
+	current := instanceCount(d)
+	if current < config.MinInstances {
+		return config.MinInstances, ScaleReasonMinimum
+	}
+	if current > config.MaxInstances {
+		return config.MaxInstances, ScaleReasonMaximum
+	}
+
+	sinceScale := time.Duration(math.MaxInt64)
+	if d.LastScaledAt != nil {
+		sinceScale = now.Sub(*d.LastScaledAt)
+	}
+
+	byRate := int(math.Ceil(load.RequestsPerSecond / float64(config.TargetRPSPerInstance)))
+	slow := load.Requests > 0 && load.AvgLatencyMs > int64(config.TargetLatencyMs)
+
+	target, reason := current, ""
+	switch {
+	case byRate > current:
+		target, reason = byRate, ScaleReasonRequestRate
+	case slow:
+		target, reason = current+1, ScaleReasonLatency
+	case byRate < current && sinceScale >= scaleDownCooldown:
+		target, reason = current-1, ScaleReasonLowLoad
+	}
+	if target > current && sinceScale < scaleUpCooldown {
+		return current, ""
+	}
+	if target > config.MaxInstances {
+		target = config.MaxInstances
+	}
+	if target < config.MinInstances {
+		target = config.MinInstances
+	}
+	if target == current {
+		return current, ""
+	}
+	return target, reason
+}
+
+// reconcileAutoscale makes one scaling pass over autoscaled deployments
+func (s *HostingService) reconcileAutoscale(now time.Time) {
+	var deployments []NativeDeployment
+	if err := s.db.Where("auto_scale = ? AND status = ?", true, StatusRunning).Find(&deployments).Error; err != nil {
+		return
+	}
+	for i := range deployments {
+		deployment := &deployments[i]
+		load := s.DeploymentLoad(deployment.ID, autoscaleWindow, now)
+		if target, reason := desiredInstances(deployment, load, now); reason != "" {
+			go s.scaleDeployment(context.Background(), deployment.ID, target, reason, load)
+		}
+	}
+
+	// Old samples only matter for the analytics history
+	s.db.Where("window_end < ?", now.Add(-loadSampleRetention)).Delete(&DeploymentLoadSample{})
+}
+
+// scaleDeployment changes the deployment's instance count. New instances
+// only receive traffic once they pass the health check; removed instances
+// stop receiving traffic before they are stopped.
+func (s *HostingService) scaleDeployment(ctx context.Context, deploymentID string, target int, reason string, load LoadStats) error {
+	if _, busy := s.containerOps.LoadOrStore(deploymentID, true); busy {
+		return ErrRestartInProgress
+	}
+	defer s.containerOps.Delete(deploymentID)
+
+	deployment, err := s.GetDeployment(deploymentID)
+	if err != nil {
+		return err
+	}
+	current := instanceCount(deployment)
+	if target == current || target < 1 {
+		return nil
+	}
+	details := ScaleDetails{From: current, To: target, Reason: reason, RequestsPerSecond: load.RequestsPerSecond, AvgLatencyMs: load.AvgLatencyMs}
+	started := time.Now()
+
+	reached := current
+	var scaleErr error
+	if target > current {
+		env := s.replacementEnv(deployment)
+		for i := current; i < target; i++ {
+			name := instanceName(deployment.ContainerID, i)
+			if err := s.runtime.StartReplacement(ctx, deployment, name, env); err != nil {
+				scaleErr = fmt.Errorf("start instance %d: %w", i, err)
+				break
+			}
+			if _, err := s.waitForReplacement(ctx, deployment, healthURL(deployment, name)); err != nil {
+				stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				_ = s.runtime.Stop(stopCtx, name)
+				cancel()
+				scaleErr = fmt.Errorf("instance %d failed its health check at %s: %w", i, deployment.HealthCheckPath, err)
+				break
+			}
+			reached = i + 1
+		}
+	} else {
+		reached = target
+	}
+
+	if reached != current {
+		now := time.Now()
+		s.db.Model(deployment).Updates(map[string]interface{}{
+			"current_instances": reached,
+			"last_scaled_at":    &now,
+		})
+		s.mu.Lock()
+		if active, ok := s.activeDeployments[deployment.ID]; ok {
+			active.CurrentInstances = reached
+			active.LastScaledAt = &now
+		}
+		s.mu.Unlock()
+	}
+
+	if reached < current {
+		// Requests stop going to removed instances once the proxy's route
+		// cache refreshes
+		if s.scaleDrain > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.scaleDrain):
+			}
+		}
+		stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		for i := current - 1; i >= reached; i-- {
+			name := instanceName(deployment.ContainerID, i)
+			if err := s.runtime.Stop(stopCtx, name); err != nil {
+				s.addLog(deployment.ID, "warn", "autoscaler", fmt.Sprintf("Failed to stop instance %s: %v", name, err))
+			}
+		}
+		cancel()
+	}
+
+	details.To = reached
+	status, message := "success", fmt.Sprintf("Scaled from %d to %d instances (%s)", current, reached, reason)
+	if scaleErr != nil {
+		details.Error = scaleErr.Error()
+		status = "error"
+		message = fmt.Sprintf("Scaling from %d to %d instances (%s) stopped at %d: %v", current, target, reason, reached, scaleErr)
+	}
+	metadata, _ := json.Marshal(details)
+	s.db.Create(&DeploymentEvent{
+		DeploymentID: deployment.ID,
+		EventType:    EventScale,
+		EventStatus:  status,
+		Message:      message,
+		Metadata:     string(metadata),
+		Duration:     time.Since(started).Milliseconds(),
+	})
+	s.broadcastScale(deployment.ID, status, message, details)
+	return scaleErr
+}
+
+// broadcastScale logs a scale change and pushes it to deployment WebSocket
+// subscribers with its details
+func (s *HostingService) broadcastScale(deploymentID, status, message string, details ScaleDetails) {
+	level := "info"
+	if status != "success" {
+		level = "warn"
+	}
+	metadata := map[string]interface{}{
+		"event":               EventScale,
+		"from":                details.From,
+		"to":                  details.To,
+		"reason":              details.Reason,
+		"requests_per_second": details.RequestsPerSecond,
+		"avg_latency_ms":      details.AvgLatencyMs,
+	}
+	encoded, _ := json.Marshal(metadata)
+	entry := LogEntry{Timestamp: time.Now(), Level: level, Source: "autoscaler", Message: message, Metadata: metadata}
+	s.db.Create(&DeploymentLog{
+		DeploymentID: deploymentID,
+		Timestamp:    entry.Timestamp,
+		Level:        level,
+		Source:       entry.Source,
+		Message:      message,
+		Metadata:     string(encoded),
+	})
+	s.logStreamer.Broadcast(deploymentID, entry)
+	s.forwardLog(deploymentID, entry)
+}
+
+// loadCounter accumulates one deployment's traffic between flushes
+type loadCounter struct {
+	requests     int64
+	errors       int64
+	latencyMsSum int64
+	latencyMsMax int64
+}
+
+// loadRecorder accumulates per-deployment traffic in the proxy
+type loadRecorder struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	counters    map[string]*loadCounter
+}
+
+func newLoadRecorder() *loadRecorder {
+	return &loadRecorder{windowStart: time.Now(), counters: make(map[string]*loadCounter)}
+}
+
+// record adds one proxied request
+func (r *loadRecorder) record(deploymentID string, latency time.Duration, status int) {
+	ms := latency.Milliseconds()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counter, ok := r.counters[deploymentID]
+	if !ok {
+		counter = &loadCounter{}
+		r.counters[deploymentID] = counter
+	}
+	counter.requests++
+	if status >= 500 {
+		counter.errors++
+	}
+	counter.latencyMsSum += ms
+	if ms > counter.latencyMsMax {
+		counter.latencyMsMax = ms
+	}
+}
+
+// drain returns the traffic since the last drain as samples and resets
+func (r *loadRecorder) drain(now time.Time) []DeploymentLoadSample {
+	r.mu.Lock()
+	counters, start := r.counters, r.windowStart
+	r.counters, r.windowStart = make(map[string]*loadCounter), now
+	r.mu.Unlock()
+
+	samples := make([]DeploymentLoadSample, 0, len(counters))
+	for deploymentID, counter := range counters {
+		samples = append(samples, DeploymentLoadSample{
+			DeploymentID: deploymentID,
+			WindowStart:  start,
+			WindowEnd:    now,
+			Requests:     counter.requests,
+			Errors:       counter.errors,
+			LatencyMsSum: counter.latencyMsSum,
+			LatencyMsMax: counter.latencyMsMax,
+		})
+	}
+	return samples
+}
+
+// statusRecorder captures the status code the deployment responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streamed responses streaming
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// flushLoadLoop periodically writes the recorded load
+func (p *HostingProxy) flushLoadLoop() {
+	ticker := time.NewTicker(loadFlushInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		p.flushLoad(now)
+	}
+}
+
+// flushLoad writes the load recorded since the last flush as samples and
+// updates each deployment's average response time
+func (p *HostingProxy) flushLoad(now time.Time) {
+	samples := p.load.drain(now)
+	if len(samples) == 0 {
+		return
+	}
+	if err := p.db.Create(&samples).Error; err != nil {
+		return
+	}
+	for _, sample := range samples {
+		if sample.Requests > 0 {
+			p.db.Model(&NativeDeployment{}).
+				Where("id = ?", sample.DeploymentID).
+				UpdateColumn("avg_response_time", sample.LatencyMsSum/sample.Requests)
+		}
+	}
+}
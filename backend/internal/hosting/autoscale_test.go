@@ -0,0 +1,170 @@
+package hosting
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDesiredInstances(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-30 * time.Second)
+	settled := now.Add(-10 * time.Minute)
+	cases := []struct {
+		name       string
+		current    int
+		lastScaled *time.Time
+		load       LoadStats
+		want       int
+		reason     string
+	}{
+		{"rate needs three", 1, nil, LoadStats{Requests: 1200, RequestsPerSecond: 130, AvgLatencyMs: 80}, 3, ScaleReasonRequestRate},
+		{"rate capped at max", 2, nil, LoadStats{Requests: 9000, RequestsPerSecond: 900, AvgLatencyMs: 80}, 4, ScaleReasonRequestRate},
+		{"slow adds one", 2, nil, LoadStats{Requests: 600, RequestsPerSecond: 60, AvgLatencyMs: 900}, 3, ScaleReasonLatency},
+		{"scale up cooldown", 1, &recent, LoadStats{Requests: 1200, RequestsPerSecond: 130, AvgLatencyMs: 80}, 1, ""},
+		{"idle removes one", 3, &settled, LoadStats{Requests: 10, RequestsPerSecond: 1, AvgLatencyMs: 40}, 2, ScaleReasonLowLoad},
+		{"scale down cooldown", 3, &recent, LoadStats{Requests: 10, RequestsPerSecond: 1, AvgLatencyMs: 40}, 3, ""},
+		{"never below min", 1, nil, LoadStats{}, 1, ""},
+		{"in range", 2, nil, LoadStats{Requests: 9000, RequestsPerSecond: 75, AvgLatencyMs: 100}, 2, ""},
+	}
+	for _, tc := range cases {
+		d := &NativeDeployment{AutoScale: true, MinInstances: 1, MaxInstances: 4, CurrentInstances: tc.current, LastScaledAt: tc.lastScaled}
+		got, reason := desiredInstances(d, tc.load, now)
+		if got != tc.want || reason != tc.reason {
+			t.Errorf("%s: got %d (%q), want %d (%q)", tc.name, got, reason, tc.want, tc.reason)
+		}
+	}
+}
+
+func TestScaleDeploymentStartsHealthyInstancesAndStopsRemovedOnes(t *testing.T) {
+	var probed []string
+	svc, db, deployment, runtime := setupRestartTest(t, func(_ context.Context, url string) (int, error) {
+		probed = append(probed, url)
+		return 200, nil
+	})
+	svc.SetScaleDrain(0)
+	logs, unsubscribe := svc.SubscribeLogs(deployment.ID)
+	defer unsubscribe()
+
+	load := LoadStats{RequestsPerSecond: 120, AvgLatencyMs: 95}
+	if err := svc.scaleDeployment(context.Background(), deployment.ID, 3, ScaleReasonRequestRate, load); err != nil {
+		t.Fatalf("scale up: %v", err)
+	}
+	var stored NativeDeployment
+	db.First(&stored, "id = ?", deployment.ID)
+	if stored.CurrentInstances != 3 || stored.LastScaledAt == nil {
+		t.Fatalf("after scale up: instances = %d, last scaled = %v", stored.CurrentInstances, stored.LastScaledAt)
+	}
+	if got := strings.Join(runtime.calls, ","); got != "start apex-abcdef012345-1,start apex-abcdef012345-2" {
+		t.Fatalf("calls = %s", got)
+	}
+	if len(probed) != 2 || probed[1] != "http://apex-abcdef012345-2:8080/ready" {
+		t.Fatalf("probed = %v", probed)
+	}
+
+	var scaleEntry *LogEntry
+	for scaleEntry == nil {
+		select {
+		case entry := <-logs:
+			if entry.Metadata["event"] == EventScale {
+				scaleEntry = &entry
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no scale broadcast")
+		}
+	}
+	if scaleEntry.Metadata["from"] != 1 || scaleEntry.Metadata["to"] != 3 || scaleEntry.Metadata["reason"] != ScaleReasonRequestRate {
+		t.Fatalf("scale metadata = %v", scaleEntry.Metadata)
+	}
+
+	runtime.calls = nil
+	if err := svc.scaleDeployment(context.Background(), deployment.ID, 1, ScaleReasonLowLoad, LoadStats{}); err != nil {
+		t.Fatalf("scale down: %v", err)
+	}
+	var scaledDown NativeDeployment
+	db.First(&scaledDown, "id = ?", deployment.ID)
+	if scaledDown.CurrentInstances != 1 {
+		t.Fatalf("after scale down: instances = %d", scaledDown.CurrentInstances)
+	}
+	if got := strings.Join(runtime.calls, ","); got != "stop apex-abcdef012345-2,stop apex-abcdef012345-1" {
+		t.Fatalf("calls = %s", got)
+	}
+
+	events, _ := svc.GetDeploymentEvents(deployment.ID, EventScale, 10)
+	if len(events) != 2 || !strings.Contains(events[0].Metadata, `"reason":"low_load"`) {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestScaleUpStopsAtUnhealthyInstance(t *testing.T) {
+	svc, db, deployment, runtime := setupRestartTest(t, func(_ context.Context, url string) (int, error) {
+		if strings.HasSuffix(url, "-2:8080/ready") {
+			return 503, nil
+		}
+		return 200, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := svc.scaleDeployment(ctx, deployment.ID, 3, ScaleReasonLatency, LoadStats{})
+	if err == nil {
+		t.Fatal("expected the unhealthy instance to fail the scale")
+	}
+	var stored NativeDeployment
+	db.First(&stored, "id = ?", deployment.ID)
+	if stored.CurrentInstances != 2 {
+		t.Fatalf("instances = %d, want the healthy instance kept", stored.CurrentInstances)
+	}
+	if got := runtime.calls[len(runtime.calls)-1]; got != "stop apex-abcdef012345-2" {
+		t.Fatalf("unhealthy instance not removed: %v", runtime.calls)
+	}
+}
+
+func TestUpdateAutoscaleValidates(t *testing.T) {
+	svc, _, deployment, _ := setupRestartTest(t, func(context.Context, string) (int, error) { return 200, nil })
+
+	if _, err := svc.UpdateAutoscale(deployment.ID, AutoscaleConfig{Enabled: true, MinInstances: 3, MaxInstances: 2}, 5); !errors.Is(err, ErrInvalidAutoscale) {
+		t.Fatalf("min > max err = %v", err)
+	}
+	if _, err := svc.UpdateAutoscale(deployment.ID, AutoscaleConfig{Enabled: true, MinInstances: 1, MaxInstances: 8}, 5); !errors.Is(err, ErrInvalidAutoscale) {
+		t.Fatalf("over plan err = %v", err)
+	}
+	updated, err := svc.UpdateAutoscale(deployment.ID, AutoscaleConfig{Enabled: true, MinInstances: 1, MaxInstances: 4, TargetLatencyMs: 250}, 5)
+	if err != nil || !updated.AutoScale || updated.MaxInstances != 4 {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	if config := AutoscaleConfigFor(updated); config.TargetRPSPerInstance != DefaultTargetRPSPerInstance || config.TargetLatencyMs != 250 {
+		t.Fatalf("config = %+v", config)
+	}
+}
+
+func TestProxyLoadSamplesFeedLoadStats(t *testing.T) {
+	svc, db, deployment, _ := setupRestartTest(t, func(context.Context, string) (int, error) { return 200, nil })
+	if err := db.AutoMigrate(&DeploymentLoadSample{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	proxy := &HostingProxy{db: db, load: newLoadRecorder()}
+	for i := 0; i < 90; i++ {
+		proxy.load.record(deployment.ID, 100*time.Millisecond, 200)
+	}
+	for i := 0; i < 10; i++ {
+		proxy.load.record(deployment.ID, 600*time.Millisecond, 502)
+	}
+	now := time.Now()
+	proxy.flushLoad(now)
+
+	stats := svc.DeploymentLoad(deployment.ID, time.Minute, now)
+	if stats.Requests != 100 || stats.AvgLatencyMs != 150 || stats.MaxLatencyMs != 600 || stats.ErrorRate != 0.1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if history := svc.LoadHistory(deployment.ID, now.Add(-time.Hour)); len(history) != 1 || history[0].Requests != 100 {
+		t.Fatalf("history = %+v", history)
+	}
+	var stored NativeDeployment
+	db.First(&stored, "id = ?", deployment.ID)
+	if stored.AvgResponseTime != 150 {
+		t.Fatalf("avg response time = %d", stored.AvgResponseTime)
+	}
+}
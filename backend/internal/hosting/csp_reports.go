@@ -0,0 +1,254 @@
+// Package hosting - CSP violation reports for hosted apps
+// Browsers report Content-Security-Policy violations to a per-deployment
+// endpoint. Reports are grouped by directive and blocked resource, so a
+// misconfigured policy shows up as a handful of rows rather than a flood.
+package hosting
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCSPDeploymentNotFound is returned for reports naming no deployment
+	ErrCSPDeploymentNotFound = errors.New("deployment not found")
+	// ErrInvalidCSPReport wraps malformed report payloads
+	ErrInvalidCSPReport = errors.New("invalid CSP report")
+	// ErrCSPRateLimited is returned when a deployment's visitors send
+	// reports faster than it is allowed to
+	ErrCSPRateLimited = errors.New("CSP report rate limit exceeded")
+)
+
+// CSPReport is a normalized CSP violation report
+type CSPReport struct {
+	DocumentURI string
+	Directive   string
+	BlockedURI  string
+	SourceFile  string
+	LineNumber  int
+	Sample      string
+	Disposition string
+}
+
+type legacyCSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ScriptSample       string `json:"script-sample"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Sample             string `json:"sample"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// ParseCSPReports reads a report-uri body (application/csp-report) or a
+// Reporting API batch (application/reports+json). Reports of other types
+// in a batch are skipped.
+func ParseCSPReports(payload []byte) ([]CSPReport, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("%w: empty body", ErrInvalidCSPReport)
+	}
+
+	if payload[0] == '[' {
+		var batch []reportingAPIReport
+		if err := json.Unmarshal(payload, &batch); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCSPReport, err)
+		}
+		reports := make([]CSPReport, 0, len(batch))
+		for _, item := range batch {
+			if item.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, CSPReport{
+				DocumentURI: item.Body.DocumentURL,
+				Directive:   item.Body.EffectiveDirective,
+				BlockedURI:  item.Body.BlockedURL,
+				SourceFile:  item.Body.SourceFile,
+				LineNumber:  item.Body.LineNumber,
+				Sample:      item.Body.Sample,
+				Disposition: item.Body.Disposition,
+			})
+		}
+		return reports, nil
+	}
+
+	var legacy legacyCSPReport
+	if err := json.Unmarshal(payload, &legacy); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSPReport, err)
+	}
+	report := legacy.Report
+	directive := report.EffectiveDirective
+	if directive == "" {
+		// Older browsers only send the violated directive with its sources
+		if fields := strings.Fields(report.ViolatedDirective); len(fields) > 0 {
+			directive = fields[0]
+		}
+	}
+	if directive == "" {
+		return nil, fmt.Errorf("%w: missing csp-report", ErrInvalidCSPReport)
+	}
+	return []CSPReport{{
+		DocumentURI: report.DocumentURI,
+		Directive:   directive,
+		BlockedURI:  report.BlockedURI,
+		SourceFile:  report.SourceFile,
+		LineNumber:  report.LineNumber,
+		Sample:      report.ScriptSample,
+		Disposition: report.Disposition,
+	}}, nil
+}
+
+// CSPReportURI returns where a deployment's CSP violations are reported.
+// apiBase is the public URL of the API, such as https://api.apex.build.
+func CSPReportURI(apiBase, deploymentID string) string {
+	apiBase = strings.TrimRight(apiBase, "/")
+	if apiBase == "" || deploymentID == "" {
+		return ""
+	}
+	return apiBase + "/api/v1/csp-reports/" + url.PathEscape(deploymentID)
+}
+
+// UpdateSecurityHeaders validates and stores a deployment's security
+// headers. nil restores the framework defaults.
+func (s *HostingService) UpdateSecurityHeaders(deploymentID string, headers *SecurityHeaders) error {
+	if err := headers.Validate(); err != nil {
+		return err
+	}
+	if headers.IsZero() {
+		headers = nil
+	}
+	return s.db.Model(&NativeDeployment{ID: deploymentID}).
+		Select("SecurityHeaders").
+		Updates(&NativeDeployment{SecurityHeaders: headers}).Error
+}
+
+// IngestCSPReports stores violation reports for a deployment and returns
+// how many were accepted before any rate limit
+func (s *HostingService) IngestCSPReports(deploymentID string, reports []CSPReport) (int, error) {
+	var deployment NativeDeployment
+	if err := s.db.Select("id", "project_id").Where("id = ?", deploymentID).First(&deployment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrCSPDeploymentNotFound
+		}
+		return 0, err
+	}
+
+	for i := range reports {
+		if !s.cspLimits.allow(deployment.ID) {
+			return i, ErrCSPRateLimited
+		}
+		if err := s.storeCSPReport(&deployment, &reports[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(reports), nil
+}
+
+func (s *HostingService) storeCSPReport(deployment *NativeDeployment, report *CSPReport) error {
+	directive := truncateRunes(strings.ToLower(strings.TrimSpace(report.Directive)), 50)
+	blocked := truncateRunes(normalizeBlockedURI(report.BlockedURI), 500)
+	sum := sha256.Sum256([]byte(directive + "\n" + blocked))
+	fingerprint := hex.EncodeToString(sum[:])
+	disposition := report.Disposition
+	if disposition != "report" {
+		disposition = "enforce"
+	}
+	now := time.Now()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var violation CSPViolation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deployment_id = ? AND fingerprint = ?", deployment.ID, fingerprint).
+			First(&violation).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			violation = CSPViolation{
+				ProjectID:    deployment.ProjectID,
+				DeploymentID: deployment.ID,
+				Fingerprint:  fingerprint,
+				Directive:    directive,
+				BlockedURI:   blocked,
+				FirstSeen:    now,
+			}
+		case err != nil:
+			return err
+		}
+		violation.DocumentURI = truncateRunes(stripQuery(report.DocumentURI), 1000)
+		violation.SourceFile = truncateRunes(stripQuery(report.SourceFile), 1000)
+		violation.LineNumber = report.LineNumber
+		violation.Sample = truncateRunes(report.Sample, 255)
+		violation.Disposition = disposition
+		violation.Count++
+		violation.LastSeen = now
+		return tx.Save(&violation).Error
+	})
+}
+
+// ListCSPViolations returns a project's CSP violations, most recently seen
+// first. deploymentID is an optional filter.
+func (s *HostingService) ListCSPViolations(projectID uint, deploymentID string, limit int) ([]CSPViolation, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := s.db.Where("project_id = ?", projectID)
+	if deploymentID != "" {
+		query = query.Where("deployment_id = ?", deploymentID)
+	}
+	var violations []CSPViolation
+	err := query.Order("last_seen DESC").Limit(limit).Find(&violations).Error
+	return violations, err
+}
+
+// normalizeBlockedURI groups blocked resources by origin; paths and query
+// strings vary per request and may carry tokens
+func normalizeBlockedURI(blocked string) string {
+	blocked = strings.TrimSpace(blocked)
+	switch strings.ToLower(blocked) {
+	case "", "self":
+		return "self"
+	case "inline", "eval", "wasm-eval", "trusted-types-policy", "trusted-types-sink":
+		return strings.ToLower(blocked)
+	}
+	parsed, err := url.Parse(blocked)
+	if err != nil || parsed.Scheme == "" {
+		return blocked
+	}
+	if parsed.Host == "" {
+		// data:, blob: and similar schemes
+		return parsed.Scheme
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+func stripQuery(raw string) string {
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		return raw[:i]
+	}
+	return raw
+}
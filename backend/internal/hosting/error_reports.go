@@ -0,0 +1,585 @@
+// Package hosting - Error reporting for hosted apps
+// Deployed apps report exceptions to a Sentry-compatible endpoint. Reports
+// are grouped per deployment by fingerprint, rate limited per deployment,
+// and kept for the UI and the Solver agent.
+package hosting
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ErrorEventsPerMinute is the sustained error report rate per deployment
+	ErrorEventsPerMinute = 60
+	// ErrorEventBurst is how many reports a deployment can send at once
+	ErrorEventBurst = 30
+	// MaxEventsPerErrorGroup is how many recent reports a group keeps
+	MaxEventsPerErrorGroup = 20
+	// errorFingerprintFrames is how many in-app frames identify an error
+	errorFingerprintFrames = 5
+)
+
+var (
+	// ErrInvalidErrorKey is returned when a report's DSN key matches no
+	// deployment of the project
+	ErrInvalidErrorKey = errors.New("invalid error reporting key")
+	// ErrErrorRateLimited is returned when a deployment sends reports faster
+	// than it is allowed to
+	ErrErrorRateLimited = errors.New("error reporting rate limit exceeded")
+	// ErrInvalidErrorReport wraps malformed report payloads
+	ErrInvalidErrorReport = errors.New("invalid error report")
+	// ErrErrorGroupNotFound is returned when an error group does not exist in
+	// the project
+	ErrErrorGroupNotFound = errors.New("error group not found")
+)
+
+// ErrorReport is a normalized error report
+type ErrorReport struct {
+	EventID     string
+	Level       string
+	Platform    string
+	Type        string
+	Message     string
+	Environment string
+	Release     string
+	URL         string
+	Frames      []ErrorFrame
+	Fingerprint []string
+	Tags        map[string]string
+	Timestamp   time.Time
+}
+
+// errorRateLimiter keeps a token bucket per deployment
+type errorRateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newErrorRateLimiter() *errorRateLimiter {
+	return &errorRateLimiter{limiters: make(map[string]*rate.Limiter)}
+}
+
+func (l *errorRateLimiter) allow(deploymentID string) bool {
+	l.mu.Lock()
+	limiter, ok := l.limiters[deploymentID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(ErrorEventsPerMinute)/60, ErrorEventBurst)
+		l.limiters[deploymentID] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Allow()
+}
+
+// ErrorReportingKey returns the deployment's DSN public key, creating it on
+// first use
+func (s *HostingService) ErrorReportingKey(deployment *NativeDeployment) (string, error) {
+	if deployment.ErrorReportingKey != "" {
+		return deployment.ErrorReportingKey, nil
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(raw)
+	if err := s.db.Model(&NativeDeployment{}).Where("id = ?", deployment.ID).
+		Update("error_reporting_key", key).Error; err != nil {
+		return "", err
+	}
+	deployment.ErrorReportingKey = key
+	return key, nil
+}
+
+// ErrorDSN returns the Sentry-style DSN a deployment reports errors to.
+// apiBase is the public URL of the API, such as https://api.apex.build.
+// Sentry SDKs post to <apiBase>/api/v1/errors/api/<project>/envelope/.
+func (s *HostingService) ErrorDSN(deployment *NativeDeployment, apiBase string) (string, error) {
+	base, err := url.Parse(strings.TrimRight(apiBase, "/"))
+	if err != nil || base.Host == "" {
+		return "", fmt.Errorf("invalid API base URL %q", apiBase)
+	}
+	key, err := s.ErrorReportingKey(deployment)
+	if err != nil {
+		return "", err
+	}
+	base.User = url.User(key)
+	base.Path += "/api/v1/errors/" + strconv.FormatUint(uint64(deployment.ProjectID), 10)
+	return base.String(), nil
+}
+
+// errorReportingEnv returns the variables that point an app's Sentry SDK
+// at its deployment's DSN. PUBLIC_API_URL must be set, and an app's own
+// SENTRY_DSN is left alone.
+func (s *HostingService) errorReportingEnv(deployment *NativeDeployment, appEnv map[string]string) map[string]string {
+	apiBase := os.Getenv("PUBLIC_API_URL")
+	if apiBase == "" {
+		return nil
+	}
+	dsn, err := s.ErrorDSN(deployment, apiBase)
+	if err != nil {
+		s.addLog(deployment.ID, "warn", "deploy", fmt.Sprintf("Error reporting disabled: %v", err))
+		return nil
+	}
+	env := map[string]string{"APEX_ERROR_DSN": dsn}
+	if _, ok := appEnv["SENTRY_DSN"]; !ok {
+		env["SENTRY_DSN"] = dsn
+		env["SENTRY_ENVIRONMENT"] = "production"
+		env["SENTRY_RELEASE"] = deployment.ID
+	}
+	return env
+}
+
+// IngestErrorReports stores reports sent with a deployment's DSN key and
+// returns how many were accepted before any rate limit
+func (s *HostingService) IngestErrorReports(projectID uint, key string, reports []ErrorReport) (int, error) {
+	if key == "" {
+		return 0, ErrInvalidErrorKey
+	}
+	var deployment NativeDeployment
+	if err := s.db.Where("project_id = ? AND error_reporting_key = ?", projectID, key).
+		First(&deployment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrInvalidErrorKey
+		}
+		return 0, err
+	}
+
+	for i := range reports {
+		if !s.errorLimits.allow(deployment.ID) {
+			return i, ErrErrorRateLimited
+		}
+		if err := s.storeErrorReport(&deployment, &reports[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(reports), nil
+}
+
+func (s *HostingService) storeErrorReport(deployment *NativeDeployment, report *ErrorReport) error {
+	if report.Timestamp.IsZero() || report.Timestamp.After(time.Now().Add(time.Minute)) {
+		report.Timestamp = time.Now()
+	}
+	if report.Level == "" {
+		report.Level = "error"
+	}
+	if report.Type == "" && report.Message == "" {
+		report.Message = "Unknown error"
+	}
+	fingerprint := errorFingerprint(report)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var group ErrorGroup
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deployment_id = ? AND fingerprint = ?", deployment.ID, fingerprint).
+			First(&group).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			group = ErrorGroup{
+				ProjectID:    deployment.ProjectID,
+				DeploymentID: deployment.ID,
+				Fingerprint:  fingerprint,
+				Status:       ErrorGroupUnresolved,
+				FirstSeen:    report.Timestamp,
+			}
+		case err != nil:
+			return err
+		case group.Status == ErrorGroupResolved:
+			// A resolved error that comes back is a regression
+			group.Status = ErrorGroupUnresolved
+		}
+		group.Type = truncateRunes(report.Type, 255)
+		group.Message = report.Message
+		group.Culprit = truncateRunes(errorCulprit(report.Frames), 500)
+		group.Level = report.Level
+		group.Platform = truncateRunes(report.Platform, 50)
+		group.Release = truncateRunes(report.Release, 200)
+		group.EventCount++
+		if report.Timestamp.After(group.LastSeen) {
+			group.LastSeen = report.Timestamp
+		}
+		if err := tx.Save(&group).Error; err != nil {
+			return err
+		}
+
+		event := ErrorEvent{
+			GroupID:      group.ID,
+			DeploymentID: deployment.ID,
+			EventID:      truncateRunes(report.EventID, 36),
+			Level:        report.Level,
+			Type:         group.Type,
+			Message:      report.Message,
+			Environment:  truncateRunes(report.Environment, 100),
+			Release:      group.Release,
+			URL:          truncateRunes(report.URL, 1000),
+			Frames:       report.Frames,
+			Tags:         report.Tags,
+			Timestamp:    report.Timestamp,
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return tx.Where("group_id = ? AND id NOT IN (?)", group.ID,
+			tx.Model(&ErrorEvent{}).Select("id").Where("group_id = ?", group.ID).
+				Order("id DESC").Limit(MaxEventsPerErrorGroup)).
+			Delete(&ErrorEvent{}).Error
+	})
+}
+
+// ListErrorGroups returns a project's error groups, most recently seen
+// first. deploymentID and status are optional filters.
+func (s *HostingService) ListErrorGroups(projectID uint, deploymentID string, status ErrorGroupStatus, limit int) ([]ErrorGroup, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := s.db.Where("project_id = ?", projectID)
+	if deploymentID != "" {
+		query = query.Where("deployment_id = ?", deploymentID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var groups []ErrorGroup
+	err := query.Order("last_seen DESC").Limit(limit).Find(&groups).Error
+	return groups, err
+}
+
+// GetErrorGroup returns an error group with its recent events, newest first
+func (s *HostingService) GetErrorGroup(projectID, groupID uint) (*ErrorGroup, []ErrorEvent, error) {
+	var group ErrorGroup
+	if err := s.db.Where("id = ? AND project_id = ?", groupID, projectID).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrErrorGroupNotFound
+		}
+		return nil, nil, err
+	}
+	var events []ErrorEvent
+	if err := s.db.Where("group_id = ?", group.ID).Order("id DESC").Find(&events).Error; err != nil {
+		return nil, nil, err
+	}
+	return &group, events, nil
+}
+
+// UpdateErrorGroupStatus resolves, ignores or reopens an error group
+func (s *HostingService) UpdateErrorGroupStatus(projectID, groupID uint, status ErrorGroupStatus) (*ErrorGroup, error) {
+	switch status {
+	case ErrorGroupUnresolved, ErrorGroupResolved, ErrorGroupIgnored:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidErrorReport, status)
+	}
+	result := s.db.Model(&ErrorGroup{}).Where("id = ? AND project_id = ?", groupID, projectID).Update("status", status)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrErrorGroupNotFound
+	}
+	group, _, err := s.GetErrorGroup(projectID, groupID)
+	return group, err
+}
+
+var (
+	errorMessageNumbers = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9a-fA-F]{8}-[0-9a-fA-F-]{27}|\d+`)
+	errorMessageQuoted  = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+)
+
+// errorFingerprint groups reports: an SDK-supplied fingerprint wins;
+// otherwise the error type and innermost in-app frames, or the type and
+// message with numbers and quoted values masked when there is no stack
+func errorFingerprint(report *ErrorReport) string {
+	var parts []string
+	for _, part := range report.Fingerprint {
+		if part != "{{ default }}" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, report.Type)
+		var frames []string
+		for i := len(report.Frames) - 1; i >= 0 && len(frames) < errorFingerprintFrames; i-- {
+			frame := report.Frames[i]
+			if frame.InApp {
+				frames = append(frames, frame.Filename+":"+frame.Function)
+			}
+		}
+		if len(frames) > 0 {
+			parts = append(parts, frames...)
+		} else {
+			message := errorMessageQuoted.ReplaceAllString(report.Message, "<v>")
+			parts = append(parts, errorMessageNumbers.ReplaceAllString(message, "<n>"))
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// errorCulprit names the innermost in-app frame, e.g. "handler (src/api.js:12)"
+func errorCulprit(frames []ErrorFrame) string {
+	for i := len(frames) - 1; i >= 0; i-- {
+		frame := frames[i]
+		if !frame.InApp {
+			continue
+		}
+		location := frame.Filename
+		if frame.Line > 0 {
+			location += ":" + strconv.Itoa(frame.Line)
+		}
+		if frame.Function == "" {
+			return location
+		}
+		return frame.Function + " (" + location + ")"
+	}
+	return ""
+}
+
+func truncateRunes(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
+
+// sentryEvent is the subset of the Sentry event payload that is kept
+type sentryEvent struct {
+	EventID     string          `json:"event_id"`
+	Timestamp   json.RawMessage `json:"timestamp"`
+	Level       string          `json:"level"`
+	Platform    string          `json:"platform"`
+	Environment string          `json:"environment"`
+	Release     string          `json:"release"`
+	Message     json.RawMessage `json:"message"`
+	LogEntry    *struct {
+		Formatted string `json:"formatted"`
+		Message   string `json:"message"`
+	} `json:"logentry"`
+	Exception   json.RawMessage `json:"exception"`
+	Fingerprint []string        `json:"fingerprint"`
+	Tags        json.RawMessage `json:"tags"`
+	Request     *struct {
+		URL string `json:"url"`
+	} `json:"request"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace *struct {
+		Frames []struct {
+			Filename string `json:"filename"`
+			AbsPath  string `json:"abs_path"`
+			Module   string `json:"module"`
+			Function string `json:"function"`
+			Lineno   int    `json:"lineno"`
+			Colno    int    `json:"colno"`
+			InApp    *bool  `json:"in_app"`
+		} `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+// ParseSentryEvent normalizes a Sentry event JSON payload, as sent to the
+// store endpoint
+func ParseSentryEvent(payload []byte) (ErrorReport, error) {
+	var event sentryEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ErrorReport{}, fmt.Errorf("%w: %v", ErrInvalidErrorReport, err)
+	}
+
+	report := ErrorReport{
+		EventID:     event.EventID,
+		Level:       event.Level,
+		Platform:    event.Platform,
+		Environment: event.Environment,
+		Release:     event.Release,
+		Fingerprint: event.Fingerprint,
+		Timestamp:   parseSentryTimestamp(event.Timestamp),
+		Tags:        parseSentryTags(event.Tags),
+	}
+	if event.Request != nil {
+		report.URL = event.Request.URL
+	}
+
+	var message string
+	if err := json.Unmarshal(event.Message, &message); err != nil && len(event.Message) > 0 {
+		var structured struct {
+			Formatted string `json:"formatted"`
+			Message   string `json:"message"`
+		}
+		if json.Unmarshal(event.Message, &structured) == nil {
+			message = firstNonEmptyString(structured.Formatted, structured.Message)
+		}
+	}
+	if message == "" && event.LogEntry != nil {
+		message = firstNonEmptyString(event.LogEntry.Formatted, event.LogEntry.Message)
+	}
+	report.Message = message
+
+	// Exceptions come as {"values": [...]} or a bare list; the last value is
+	// the one that was raised
+	var exceptions []sentryException
+	var wrapped struct {
+		Values []sentryException `json:"values"`
+	}
+	if json.Unmarshal(event.Exception, &wrapped) == nil && len(wrapped.Values) > 0 {
+		exceptions = wrapped.Values
+	} else {
+		_ = json.Unmarshal(event.Exception, &exceptions)
+	}
+	if len(exceptions) > 0 {
+		raised := exceptions[len(exceptions)-1]
+		report.Type = raised.Type
+		if raised.Value != "" {
+			report.Message = raised.Value
+		}
+		if raised.Stacktrace != nil {
+			for _, frame := range raised.Stacktrace.Frames {
+				filename := firstNonEmptyString(frame.Filename, frame.AbsPath, frame.Module)
+				inApp := frame.InApp == nil || *frame.InApp
+				if frame.InApp == nil && strings.Contains(filename, "node_modules") {
+					inApp = false
+				}
+				report.Frames = append(report.Frames, ErrorFrame{
+					Filename: filename,
+					Function: frame.Function,
+					Line:     frame.Lineno,
+					Column:   frame.Colno,
+					InApp:    inApp,
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+// ParseSentryEnvelope returns the error events in a Sentry envelope and the
+// DSN from its header. Other item types (transactions, sessions,
+// attachments) are skipped.
+func ParseSentryEnvelope(body []byte) ([]ErrorReport, string, error) {
+	reader := bufio.NewReader(bytes.NewReader(body))
+	headerLine, err := readEnvelopeLine(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: missing envelope header", ErrInvalidErrorReport)
+	}
+	var header struct {
+		DSN string `json:"dsn"`
+	}
+	if err := json.Unmarshal(headerLine, &header); err != nil {
+		return nil, "", fmt.Errorf("%w: envelope header: %v", ErrInvalidErrorReport, err)
+	}
+
+	var reports []ErrorReport
+	for {
+		itemLine, err := readEnvelopeLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if len(bytes.TrimSpace(itemLine)) == 0 {
+			continue
+		}
+		var item struct {
+			Type   string `json:"type"`
+			Length *int   `json:"length"`
+		}
+		if err := json.Unmarshal(itemLine, &item); err != nil {
+			return nil, "", fmt.Errorf("%w: item header: %v", ErrInvalidErrorReport, err)
+		}
+
+		var payload []byte
+		if item.Length != nil {
+			if *item.Length < 0 || *item.Length > len(body) {
+				return nil, "", fmt.Errorf("%w: item length", ErrInvalidErrorReport)
+			}
+			payload = make([]byte, *item.Length)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return nil, "", fmt.Errorf("%w: truncated item", ErrInvalidErrorReport)
+			}
+			if next, err := reader.Peek(1); err == nil && next[0] == '\n' {
+				_, _ = reader.Discard(1)
+			}
+		} else if payload, err = readEnvelopeLine(reader); err != nil && err != io.EOF {
+			return nil, "", err
+		}
+
+		if item.Type != "event" {
+			continue
+		}
+		report, err := ParseSentryEvent(payload)
+		if err != nil {
+			return nil, "", err
+		}
+		reports = append(reports, report)
+	}
+	return reports, header.DSN, nil
+}
+
+func readEnvelopeLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return bytes.TrimSuffix(line, []byte("\n")), err
+}
+
+// parseSentryTimestamp accepts seconds since the epoch or RFC 3339
+func parseSentryTimestamp(raw json.RawMessage) time.Time {
+	var seconds float64
+	if json.Unmarshal(raw, &seconds) == nil && seconds > 0 {
+		whole := int64(seconds)
+		return time.Unix(whole, int64((seconds-float64(whole))*1e9))
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		if parsed, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			return parsed
+		}
+		if parsed, err := time.Parse("2006-01-02T15:04:05", text); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+// parseSentryTags accepts an object or a list of [key, value] pairs
+func parseSentryTags(raw json.RawMessage) map[string]string {
+	tags := map[string]string{}
+	if json.Unmarshal(raw, &tags) == nil {
+		return tags
+	}
+	var pairs [][2]string
+	if json.Unmarshal(raw, &pairs) == nil {
+		for _, pair := range pairs {
+			tags[pair[0]] = pair[1]
+		}
+		return tags
+	}
+	return nil
+}
+
+func firstNonEmptyString(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package hosting
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupErrorReportTest(t *testing.T) (*HostingService, *NativeDeployment, string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&NativeDeployment{}, &DeploymentLog{}, &ErrorGroup{}, &ErrorEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewHostingService(db)
+	t.Cleanup(svc.Close)
+
+	deployment := &NativeDeployment{
+		ID:        "abcdef0123456789abcdef0123456789abcd",
+		ProjectID: 9,
+		UserID:    4,
+		Subdomain: "crashy-app",
+		Status:    StatusRunning,
+	}
+	if err := db.Create(deployment).Error; err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+	dsn, err := svc.ErrorDSN(deployment, "https://api.apex.test/")
+	if err != nil {
+		t.Fatalf("dsn: %v", err)
+	}
+	want := "https://" + deployment.ErrorReportingKey + "@api.apex.test/api/v1/errors/9"
+	if dsn != want {
+		t.Fatalf("dsn = %q, want %q", dsn, want)
+	}
+	return svc, deployment, deployment.ErrorReportingKey
+}
+
+const sentryEnvelope = `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","dsn":"https://KEY@api.apex.test/api/v1/errors/9"}
+{"type":"session"}
+{"started":"2026-10-16T10:00:00Z"}
+{"type":"event","length":LEN}
+EVENT
+`
+
+const sentryEventJSON = `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","timestamp":1792144800.5,"platform":"node","level":"error","release":"v1","request":{"url":"https://crashy-app.apex.app/api/orders"},"tags":[["route","/api/orders"]],"exception":{"values":[{"type":"TypeError","value":"Cannot read properties of undefined (reading 'id')","stacktrace":{"frames":[{"filename":"node_modules/express/lib/router.js","function":"handle","lineno":10},{"filename":"/app/src/orders.js","function":"createOrder","lineno":42,"colno":7,"in_app":true}]}}]}}`
+
+func TestParseSentryEnvelopeKeepsErrorEvents(t *testing.T) {
+	body := strings.Replace(sentryEnvelope, "LEN", strconv.Itoa(len(sentryEventJSON)), 1)
+	body = strings.Replace(body, "EVENT", sentryEventJSON, 1)
+
+	reports, dsn, err := ParseSentryEnvelope([]byte(body))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if dsn != "https://KEY@api.apex.test/api/v1/errors/9" {
+		t.Fatalf("dsn = %q", dsn)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("reports = %d, want 1 (sessions are skipped)", len(reports))
+	}
+	report := reports[0]
+	if report.Type != "TypeError" || !strings.HasPrefix(report.Message, "Cannot read properties") {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(report.Frames) != 2 || report.Frames[0].InApp || !report.Frames[1].InApp {
+		t.Fatalf("frames = %+v", report.Frames)
+	}
+	if report.Tags["route"] != "/api/orders" || report.URL == "" || report.Timestamp.Unix() != 1792144800 {
+		t.Fatalf("report metadata = %+v", report)
+	}
+	if got := errorCulprit(report.Frames); got != "createOrder (/app/src/orders.js:42)" {
+		t.Fatalf("culprit = %q", got)
+	}
+}
+
+func TestIngestErrorReportsGroupsAndReopens(t *testing.T) {
+	svc, deployment, key := setupErrorReportTest(t)
+	report, err := ParseSentryEvent([]byte(sentryEventJSON))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if _, err := svc.IngestErrorReports(9, "wrong-key", []ErrorReport{report}); !errors.Is(err, ErrInvalidErrorKey) {
+		t.Fatalf("wrong key err = %v", err)
+	}
+	if _, err := svc.IngestErrorReports(10, key, []ErrorReport{report}); !errors.Is(err, ErrInvalidErrorKey) {
+		t.Fatalf("other project err = %v", err)
+	}
+
+	// Same stack, different values in the message: one group
+	second := report
+	second.Message = "Cannot read properties of undefined (reading 'total')"
+	if n, err := svc.IngestErrorReports(9, key, []ErrorReport{report, second}); err != nil || n != 2 {
+		t.Fatalf("ingest = %d, %v", n, err)
+	}
+	groups, err := svc.ListErrorGroups(9, deployment.ID, "", 0)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("groups = %+v, %v", groups, err)
+	}
+	if groups[0].EventCount != 2 || groups[0].Culprit != "createOrder (/app/src/orders.js:42)" {
+		t.Fatalf("group = %+v", groups[0])
+	}
+
+	if _, err := svc.UpdateErrorGroupStatus(9, groups[0].ID, ErrorGroupResolved); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if _, err := svc.IngestErrorReports(9, key, []ErrorReport{report}); err != nil {
+		t.Fatalf("ingest regression: %v", err)
+	}
+	group, events, err := svc.GetErrorGroup(9, groups[0].ID)
+	if err != nil {
+		t.Fatalf("get group: %v", err)
+	}
+	if group.Status != ErrorGroupUnresolved {
+		t.Fatalf("recurring resolved error should reopen, status = %s", group.Status)
+	}
+	if len(events) != 3 || events[0].Tags["route"] != "/api/orders" {
+		t.Fatalf("events = %+v", events)
+	}
+	if _, _, err := svc.GetErrorGroup(10, groups[0].ID); !errors.Is(err, ErrErrorGroupNotFound) {
+		t.Fatalf("other project group err = %v", err)
+	}
+}
+
+func TestIngestErrorReportsRateLimitsAndTrims(t *testing.T) {
+	svc, _, key := setupErrorReportTest(t)
+	reports := make([]ErrorReport, ErrorEventBurst+5)
+	for i := range reports {
+		reports[i] = ErrorReport{Type: "Error", Message: "boom " + strconv.Itoa(i)}
+	}
+
+	accepted, err := svc.IngestErrorReports(9, key, reports)
+	if !errors.Is(err, ErrErrorRateLimited) || accepted != ErrorEventBurst {
+		t.Fatalf("accepted = %d, err = %v", accepted, err)
+	}
+	groups, _ := svc.ListErrorGroups(9, "", ErrorGroupUnresolved, 0)
+	if len(groups) != 1 {
+		t.Fatalf("stackless errors differing by numbers should share a group, got %d", len(groups))
+	}
+	_, events, _ := svc.GetErrorGroup(9, groups[0].ID)
+	if len(events) != MaxEventsPerErrorGroup {
+		t.Fatalf("events kept = %d, want %d", len(events), MaxEventsPerErrorGroup)
+	}
+}
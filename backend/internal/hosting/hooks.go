@@ -0,0 +1,58 @@
+package hosting
+
+import (
+	"context"
+	"errors"
+
+	"apex-build/internal/buildhooks"
+)
+
+// PreDeployHooks runs the pre_deploy hooks a project declares in apex.yaml.
+// Implemented by *buildhooks.Runner.
+type PreDeployHooks interface {
+	Run(ctx context.Context, stage buildhooks.Stage, req buildhooks.Request, emit func(buildhooks.Event)) (*buildhooks.StageResult, error)
+}
+
+// SetPreDeployHooks runs the project's pre_deploy hooks before provisioning
+func (s *HostingService) SetPreDeployHooks(hooks PreDeployHooks) {
+	s.preDeployHooks = hooks
+}
+
+// runPreDeployHooks runs the pre_deploy hooks against the deployment's files,
+// or the project's stored files when none were sent, logging their output
+// with source "hooks". It returns false after failing the deployment when a
+// hook blocked it.
+func (s *HostingService) runPreDeployHooks(ctx context.Context, deployment *NativeDeployment, config *DeploymentConfig) bool {
+	if s.preDeployHooks == nil {
+		return true
+	}
+	req := buildhooks.Request{UserID: deployment.UserID}
+	if len(config.Files) == 0 {
+		req.ProjectID = deployment.ProjectID
+	} else {
+		req.Files = make(map[string]string, len(config.Files))
+		for _, f := range config.Files {
+			if !f.IsDir {
+				req.Files[f.Path] = f.Content
+			}
+		}
+	}
+	result, err := s.preDeployHooks.Run(ctx, buildhooks.StagePreDeploy, req, func(ev buildhooks.Event) {
+		if level, message := ev.LogLine(); message != "" {
+			s.addLog(deployment.ID, level, "hooks", message)
+		}
+	})
+	if err != nil {
+		if errors.Is(err, buildhooks.ErrInvalidConfig) {
+			s.failDeployment(deployment, err.Error())
+			return false
+		}
+		s.addLog(deployment.ID, "warn", "hooks", "pre_deploy hooks skipped: "+err.Error())
+		return true
+	}
+	if msg := result.BlockError(); msg != "" {
+		s.failDeployment(deployment, msg)
+		return false
+	}
+	return true
+}
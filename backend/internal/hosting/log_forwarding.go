@@ -0,0 +1,30 @@
+package hosting
+
+import "time"
+
+// LogForwarder receives every deployment log line, for example to send it
+// to a project's log drains. Implementations must not block.
+type LogForwarder interface {
+	ForwardDeploymentLog(projectID uint, deploymentID string, at time.Time, level, source, message string)
+}
+
+// SetLogForwarder forwards deployment and worker logs
+func (s *HostingService) SetLogForwarder(forwarder LogForwarder) {
+	s.logForwarder = forwarder
+}
+
+// forwardLog hands a log line to the forwarder with its project
+func (s *HostingService) forwardLog(deploymentID string, entry LogEntry) {
+	if s.logForwarder == nil {
+		return
+	}
+	projectID, ok := s.deploymentProjects.Load(deploymentID)
+	if !ok {
+		var deployment NativeDeployment
+		if err := s.db.Unscoped().Select("id", "project_id").Where("id = ?", deploymentID).First(&deployment).Error; err != nil {
+			return
+		}
+		projectID, _ = s.deploymentProjects.LoadOrStore(deploymentID, deployment.ProjectID)
+	}
+	s.logForwarder.ForwardDeploymentLog(projectID.(uint), deploymentID, entry.Timestamp, entry.Level, entry.Source, entry.Message)
+}
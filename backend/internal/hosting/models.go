@@ -83,6 +83,12 @@ type NativeDeployment struct {
 	MaxInstances     int  `json:"max_instances" gorm:"default:3"`
 	CurrentInstances int  `json:"current_instances" gorm:"default:0"`
 
+	// Autoscaling targets: instances are added when the request rate per
+	// instance or the average latency goes over target
+	TargetRPSPerInstance int        `json:"target_rps_per_instance" gorm:"default:50"`
+	TargetLatencyMs      int        `json:"target_latency_ms" gorm:"default:500"`
+	LastScaledAt         *time.Time `json:"last_scaled_at,omitempty"`
+
 	// Health check configuration
 	HealthCheckPath     string `json:"health_check_path" gorm:"default:'/health'"`
 	HealthCheckInterval int    `json:"health_check_interval" gorm:"default:30"` // seconds
@@ -91,6 +97,11 @@ type NativeDeployment struct {
 	MaxRestarts         int    `json:"max_restarts" gorm:"default:3"`
 	RestartCount        int    `json:"restart_count" gorm:"default:0"`
 
+	// Restart controller state: automatic restarts wait until NextRestartAt
+	// after a failure and stop once a crash loop is detected
+	NextRestartAt       *time.Time `json:"next_restart_at,omitempty"`
+	CrashLoopDetectedAt *time.Time `json:"crash_loop_detected_at,omitempty"`
+
 	// Always-On configuration (Replit parity feature)
 	// When enabled, deployment stays running 24/7 with automatic restart on crash
 	AlwaysOn          bool       `json:"always_on" gorm:"default:false"`
@@ -105,6 +116,12 @@ type NativeDeployment struct {
 	SSLCertificateID string `json:"ssl_certificate_id,omitempty" gorm:"type:varchar(50)"`
 	SSLStatus        string `json:"ssl_status" gorm:"default:'pending'"` // pending, active, expired, error
 
+	// Public key of the deployment's error reporting DSN
+	ErrorReportingKey string `json:"-" gorm:"type:varchar(32);index"`
+
+	// Security headers added by the proxy; nil uses the framework defaults
+	SecurityHeaders *SecurityHeaders `json:"security_headers,omitempty" gorm:"type:text;serializer:json"`
+
 	// Metrics
 	TotalRequests   int64      `json:"total_requests" gorm:"default:0"`
 	AvgResponseTime int64      `json:"avg_response_time" gorm:"default:0"` // ms
@@ -273,7 +290,7 @@ func (d *NativeDeployment) ShouldStayAwake() bool {
 
 // NeedsCrashRecovery returns true if the deployment crashed and should be auto-restarted
 func (d *NativeDeployment) NeedsCrashRecovery() bool {
-	return d.AlwaysOn && d.Status == StatusFailed && d.RestartCount < d.MaxRestarts
+	return d.AlwaysOn && d.Status == StatusFailed && d.RestartCount < d.MaxRestarts && d.CrashLoopDetectedAt == nil
 }
 
 // GetFullURL returns the full URL for the deployment
@@ -396,3 +413,171 @@ func (c *SSLCertificate) NeedsRenewal() bool {
 	// Renew 30 days before expiry
 	return time.Now().Add(30 * 24 * time.Hour).After(c.ExpiresAt)
 }
+
+// WorkerStatus represents the state of a background worker process
+type WorkerStatus string
+
+const (
+	WorkerRunning  WorkerStatus = "running"
+	WorkerSleeping WorkerStatus = "sleeping" // Scaled to zero while idle
+	WorkerStopped  WorkerStatus = "stopped"
+	WorkerFailed   WorkerStatus = "failed"
+)
+
+// WorkerProcess is a background worker process type for a project's native
+// hosting. Workers run the project's deployed image with their own start
+// command, no exposed port, and optionally a managed Redis-backed queue.
+type WorkerProcess struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	ProjectID uint `json:"project_id" gorm:"not null;index"`
+	UserID    uint `json:"user_id" gorm:"not null;index"`
+
+	// Deployment whose image the worker currently runs
+	DeploymentID string `json:"deployment_id,omitempty" gorm:"type:varchar(36);index"`
+
+	// Process configuration
+	Name        string `json:"name" gorm:"not null;type:varchar(63)"`
+	Command     string `json:"command" gorm:"not null;type:varchar(500)"`
+	Replicas    int    `json:"replicas" gorm:"default:1"`
+	MemoryLimit int64  `json:"memory_limit" gorm:"default:256"` // MB
+	CPULimit    int64  `json:"cpu_limit" gorm:"default:250"`    // millicores
+
+	// Scale-to-zero: stop the worker after IdleTimeoutMinutes without web
+	// traffic and start it again on the next request
+	ScaleToZero        bool `json:"scale_to_zero" gorm:"default:false"`
+	IdleTimeoutMinutes int  `json:"idle_timeout_minutes" gorm:"default:15"`
+
+	// Managed queue (Redis) shared by the project's web and worker processes
+	QueueEnabled    bool  `json:"queue_enabled" gorm:"default:false"`
+	QueueDatabaseID *uint `json:"queue_database_id,omitempty"`
+
+	// Runtime state
+	Status          WorkerStatus `json:"status" gorm:"type:varchar(20);default:'stopped'"`
+	CurrentReplicas int          `json:"current_replicas" gorm:"default:0"`
+	ContainerName   string       `json:"container_name,omitempty" gorm:"type:varchar(100)"`
+	ErrorMessage    string       `json:"error_message,omitempty" gorm:"type:text"`
+	RestartCount    int          `json:"restart_count" gorm:"default:0"`
+	StartedAt       *time.Time   `json:"started_at,omitempty"`
+	SleptAt         *time.Time   `json:"slept_at,omitempty"`
+	LastActivityAt  *time.Time   `json:"last_activity_at,omitempty"`
+	UptimeSeconds   int64        `json:"uptime_seconds" gorm:"default:0"`
+}
+
+// TableName specifies the table name for WorkerProcess
+func (WorkerProcess) TableName() string {
+	return "worker_processes"
+}
+
+// LogSource returns the deployment log source for the worker's output
+func (w *WorkerProcess) LogSource() string {
+	return "worker:" + w.Name
+}
+
+// ErrorGroupStatus is the triage state of a group of reported errors
+type ErrorGroupStatus string
+
+const (
+	ErrorGroupUnresolved ErrorGroupStatus = "unresolved"
+	ErrorGroupResolved   ErrorGroupStatus = "resolved" // Reopens if the error recurs
+	ErrorGroupIgnored    ErrorGroupStatus = "ignored"
+)
+
+// ErrorFrame is one stack frame of a reported error, oldest call first
+type ErrorFrame struct {
+	Filename string `json:"filename"`
+	Function string `json:"function,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	InApp    bool   `json:"in_app"`
+}
+
+// ErrorGroup collects the reports of one error in one deployment. Reports
+// share a group when their fingerprint matches.
+type ErrorGroup struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID    uint   `json:"project_id" gorm:"not null;index"`
+	DeploymentID string `json:"deployment_id" gorm:"not null;type:varchar(36);uniqueIndex:idx_error_groups_fingerprint"`
+	Fingerprint  string `json:"fingerprint" gorm:"not null;type:varchar(64);uniqueIndex:idx_error_groups_fingerprint"`
+
+	// Summary of the latest report
+	Type     string `json:"type" gorm:"type:varchar(255)"`
+	Message  string `json:"message" gorm:"type:text"`
+	Culprit  string `json:"culprit,omitempty" gorm:"type:varchar(500)"` // Innermost in-app frame
+	Level    string `json:"level" gorm:"type:varchar(20);default:'error'"`
+	Platform string `json:"platform,omitempty" gorm:"type:varchar(50)"`
+	Release  string `json:"release,omitempty" gorm:"type:varchar(200)"`
+
+	Status     ErrorGroupStatus `json:"status" gorm:"type:varchar(20);default:'unresolved';index"`
+	EventCount int64            `json:"event_count" gorm:"default:0"`
+	FirstSeen  time.Time        `json:"first_seen"`
+	LastSeen   time.Time        `json:"last_seen" gorm:"index"`
+}
+
+// TableName specifies the table name for ErrorGroup
+func (ErrorGroup) TableName() string {
+	return "error_groups"
+}
+
+// ErrorEvent is a single error report. Only the most recent events of each
+// group are kept.
+type ErrorEvent struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	GroupID      uint   `json:"group_id" gorm:"not null;index"`
+	DeploymentID string `json:"deployment_id" gorm:"not null;type:varchar(36);index"`
+	EventID      string `json:"event_id" gorm:"type:varchar(36)"`
+
+	Level       string            `json:"level" gorm:"type:varchar(20)"`
+	Type        string            `json:"type" gorm:"type:varchar(255)"`
+	Message     string            `json:"message" gorm:"type:text"`
+	Environment string            `json:"environment,omitempty" gorm:"type:varchar(100)"`
+	Release     string            `json:"release,omitempty" gorm:"type:varchar(200)"`
+	URL         string            `json:"url,omitempty" gorm:"type:varchar(1000)"`
+	Frames      []ErrorFrame      `json:"frames" gorm:"type:text;serializer:json"`
+	Tags        map[string]string `json:"tags,omitempty" gorm:"type:text;serializer:json"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// TableName specifies the table name for ErrorEvent
+func (ErrorEvent) TableName() string {
+	return "error_events"
+}
+
+// CSPViolation collects the Content-Security-Policy violation reports of
+// one directive and blocked resource in one deployment
+type CSPViolation struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID    uint   `json:"project_id" gorm:"not null;index"`
+	DeploymentID string `json:"deployment_id" gorm:"not null;type:varchar(36);uniqueIndex:idx_csp_violations_fingerprint"`
+	Fingerprint  string `json:"-" gorm:"not null;type:varchar(64);uniqueIndex:idx_csp_violations_fingerprint"`
+
+	Directive  string `json:"directive" gorm:"type:varchar(50)"`
+	BlockedURI string `json:"blocked_uri" gorm:"type:varchar(500)"` // Origin, or inline/eval/data/blob
+
+	// Latest report
+	DocumentURI string `json:"document_uri,omitempty" gorm:"type:varchar(1000)"`
+	SourceFile  string `json:"source_file,omitempty" gorm:"type:varchar(1000)"`
+	LineNumber  int    `json:"line_number,omitempty"`
+	Sample      string `json:"sample,omitempty" gorm:"type:varchar(255)"`
+	Disposition string `json:"disposition" gorm:"type:varchar(20)"` // enforce, report
+
+	Count     int64     `json:"count" gorm:"default:0"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" gorm:"index"`
+}
+
+// TableName specifies the table name for CSPViolation
+func (CSPViolation) TableName() string {
+	return "csp_violations"
+}
@@ -0,0 +1,54 @@
+package hosting
+
+import (
+	"context"
+	"log"
+)
+
+// ProjectTokenSource supplies the service token variables that let a
+// project's app call back into the project API
+type ProjectTokenSource interface {
+	Env(projectID uint) (map[string]string, error)
+}
+
+// SetProjectTokens injects project service tokens into deployed apps and
+// their workers
+func (s *HostingService) SetProjectTokens(tokens ProjectTokenSource) {
+	s.projectTokens = tokens
+}
+
+// projectTokenEnv returns the project's service token variables, or nil
+// when the project has no token
+func (s *HostingService) projectTokenEnv(projectID uint) map[string]string {
+	if s.projectTokens == nil {
+		return nil
+	}
+	env, err := s.projectTokens.Env(projectID)
+	if err != nil {
+		return nil
+	}
+	return env
+}
+
+// ProjectTokenRotated restarts the project's running deployment and workers
+// in the background so they pick up the rotated token before the previous
+// one expires
+func (s *HostingService) ProjectTokenRotated(projectID uint) {
+	deployment := s.runningDeployment(projectID)
+	if deployment == nil {
+		return
+	}
+	go func() {
+		if err := s.restartWithReason(deployment.ID, RestartTokenRotated); err != nil {
+			log.Printf("Deployment %s: restart after token rotation failed: %v", deployment.ID, err)
+		}
+		var workers []WorkerProcess
+		if err := s.db.Where("project_id = ? AND status = ?", projectID, WorkerRunning).Find(&workers).Error; err != nil {
+			return
+		}
+		for i := range workers {
+			s.stopWorker(&workers[i], WorkerStopped, "Restarting worker for the rotated project token...")
+			s.startWorker(context.Background(), &workers[i], deployment)
+		}
+	}()
+}
@@ -10,13 +10,26 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"apex-build/internal/wsproxy"
+
 	"gorm.io/gorm"
 )
 
+// StickyCookieName pins a client to one instance of a multi-instance
+// always-on deployment
+const StickyCookieName = "apex_instance"
+
+// DefaultMaxWebSocketConns is the WebSocket connection limit per deployment
+// instance
+const DefaultMaxWebSocketConns = 250
+
 // HostingProxy routes incoming requests from *.apex.app to the correct deployment
 type HostingProxy struct {
 	db            *gorm.DB
@@ -24,12 +37,29 @@ type HostingProxy struct {
 	proxyCache    sync.Map // subdomain -> *httputil.ReverseProxy
 	routeCache    sync.Map // subdomain -> *NativeDeployment
 	cacheTTL      time.Duration
+
+	// WebSocket tunnels
+	wsLimiter     *wsproxy.Limiter
+	wsIdleTimeout time.Duration
+	maxWSConns    int
+	nextInstance  uint64
+
+	// Request rate and latency, flushed to load samples for the autoscaler
+	load *loadRecorder
+
+	// Public API URL that CSP violation reports are sent to
+	cspReportBase string
 }
 
 // ProxyConfig holds proxy configuration
 type ProxyConfig struct {
 	HostingDomain string        // e.g., "apex.app"
 	CacheTTL      time.Duration // Cache TTL for route lookups
+
+	WebSocketIdleTimeout time.Duration // Close WebSockets idle this long (default 5m)
+	MaxWebSocketConns    int           // Open WebSockets per deployment instance (default 250)
+
+	CSPReportBaseURL string // Public API URL for CSP violation reports (default PUBLIC_API_URL)
 }
 
 // NewHostingProxy creates a new hosting proxy
@@ -45,10 +75,25 @@ func NewHostingProxy(db *gorm.DB, config *ProxyConfig) *HostingProxy {
 		db:            db,
 		hostingDomain: config.HostingDomain,
 		cacheTTL:      config.CacheTTL,
+		wsLimiter:     wsproxy.NewLimiter(),
+		wsIdleTimeout: config.WebSocketIdleTimeout,
+		maxWSConns:    config.MaxWebSocketConns,
+		cspReportBase: config.CSPReportBaseURL,
+		load:          newLoadRecorder(),
+	}
+	if proxy.cspReportBase == "" {
+		proxy.cspReportBase = os.Getenv("PUBLIC_API_URL")
+	}
+	if proxy.wsIdleTimeout <= 0 {
+		proxy.wsIdleTimeout = wsproxy.DefaultIdleTimeout
+	}
+	if proxy.maxWSConns <= 0 {
+		proxy.maxWSConns = DefaultMaxWebSocketConns
 	}
 
 	// Start cache cleanup goroutine
 	go proxy.cleanupCache()
+	go proxy.flushLoadLoop()
 
 	return proxy
 }
@@ -157,8 +202,11 @@ func (p *HostingProxy) getDeployment(subdomain string) (*NativeDeployment, error
 
 // proxyRequest forwards the request to the deployment's container
 func (p *HostingProxy) proxyRequest(w http.ResponseWriter, r *http.Request, deployment *NativeDeployment) {
-	// Get or create reverse proxy for this deployment
-	proxy := p.getOrCreateProxy(deployment)
+	instance, pin := p.selectInstance(r, deployment)
+	upgrade := wsproxy.IsUpgrade(r)
+
+	// Get or create reverse proxy for this deployment instance
+	proxy := p.getOrCreateProxy(deployment, instance, upgrade)
 	if proxy == nil {
 		p.serveError(w, r, "Failed to create proxy")
 		return
@@ -170,30 +218,113 @@ func (p *HostingProxy) proxyRequest(w http.ResponseWriter, r *http.Request, depl
 	// Update last request timestamp
 	go p.updateLastRequest(deployment.ID)
 
-	// Proxy the request
-	proxy.ServeHTTP(w, r)
+	if upgrade {
+		limit := p.maxWSConns * instanceCount(deployment)
+		release, ok := p.wsLimiter.Acquire(deployment.ID, limit)
+		if !ok {
+			w.Header().Set("Retry-After", "5")
+			p.serveError(w, r, "Too many open WebSocket connections")
+			return
+		}
+		defer release()
+
+		// The idle timeout on the backend connection bounds the tunnel
+		proxy.ServeHTTP(w, r)
+		return
+	}
+
+	if pin {
+		http.SetCookie(w, &http.Cookie{
+			Name:     StickyCookieName,
+			Value:    strconv.Itoa(instance),
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	// Proxy the request, timing it for the autoscaler
+	started := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	proxy.ServeHTTP(recorder, r)
+	p.load.record(deployment.ID, time.Since(started), recorder.status)
+}
+
+// instanceCount returns how many instances serve the deployment
+func instanceCount(deployment *NativeDeployment) int {
+	if deployment.CurrentInstances > 1 {
+		return deployment.CurrentInstances
+	}
+	return 1
+}
+
+// instanceName is the container name of a deployment instance
+func instanceName(container string, instance int) string {
+	if instance > 0 {
+		return fmt.Sprintf("%s-%d", container, instance)
+	}
+	return container
+}
+
+// selectInstance picks the instance that serves a request. Multi-instance
+// always-on deployments pin each client with a cookie, so reconnecting
+// WebSockets and in-memory sessions land on the same instance; the bool is
+// true when the pin must be set. Other deployments round-robin.
+func (p *HostingProxy) selectInstance(r *http.Request, deployment *NativeDeployment) (int, bool) {
+	n := instanceCount(deployment)
+	if n == 1 {
+		return 0, false
+	}
+	if deployment.AlwaysOn {
+		if cookie, err := r.Cookie(StickyCookieName); err == nil {
+			if instance, err := strconv.Atoi(cookie.Value); err == nil && instance >= 0 && instance < n {
+				return instance, false
+			}
+		}
+	}
+	instance := int(atomic.AddUint64(&p.nextInstance, 1) % uint64(n))
+	return instance, deployment.AlwaysOn
 }
 
-// getOrCreateProxy returns a reverse proxy for the deployment
-func (p *HostingProxy) getOrCreateProxy(deployment *NativeDeployment) *httputil.ReverseProxy {
+// getOrCreateProxy returns a reverse proxy for a deployment instance.
+// Upgrade requests get their own proxy whose backend connections close
+// after the WebSocket idle timeout.
+func (p *HostingProxy) getOrCreateProxy(deployment *NativeDeployment, instance int, upgrade bool) *httputil.ReverseProxy {
+	cacheKey := fmt.Sprintf("%s#%d", deployment.ID, instance)
+	if upgrade {
+		cacheKey += "#ws"
+	}
+
 	// Check cache
-	if cached, ok := p.proxyCache.Load(deployment.ID); ok {
+	if cached, ok := p.proxyCache.Load(cacheKey); ok {
 		return cached.(*httputil.ReverseProxy)
 	}
 
 	// Create target URL
 	// In production, this would be the container's internal address
-	targetURL := p.getTargetURL(deployment)
+	targetURL := p.getTargetURL(deployment, instance)
 	if targetURL == nil {
 		return nil
 	}
 
+	var transport http.RoundTripper = &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if upgrade {
+		transport = wsproxy.NewTransport(p.wsIdleTimeout)
+	}
+
 	// Create reverse proxy with custom settings
 	proxy := &httputil.ReverseProxy{
 		Director: func(req *http.Request) {
+			// Keep the public Host header: apps build absolute URLs from it
+			// and WebSocket servers compare it with Origin
 			req.URL.Scheme = targetURL.Scheme
 			req.URL.Host = targetURL.Host
-			req.Host = targetURL.Host
 
 			// Add forwarding headers
 			if clientIP, _, err := strings.Cut(req.RemoteAddr, ":"); err {
@@ -208,10 +339,15 @@ func (p *HostingProxy) getOrCreateProxy(deployment *NativeDeployment) *httputil.
 			req.Header.Set("X-Apex-Subdomain", deployment.Subdomain)
 		},
 		ModifyResponse: func(resp *http.Response) error {
-			// Add security headers
+			// Add security headers. Proxies are cached per instance, so read
+			// the configuration from the route cache to pick up changes.
 			resp.Header.Set("X-Content-Type-Options", "nosniff")
-			resp.Header.Set("X-Frame-Options", "SAMEORIGIN")
 			resp.Header.Set("X-XSS-Protection", "1; mode=block")
+			current := deployment
+			if fresh, err := p.getDeployment(deployment.Subdomain); err == nil && fresh.ID == deployment.ID {
+				current = fresh
+			}
+			current.SecurityHeaders.Apply(resp.Header, current.Framework, CSPReportURI(p.cspReportBase, current.ID))
 
 			// Add cache headers for static assets
 			if p.isStaticAsset(resp.Request.URL.Path) {
@@ -224,22 +360,19 @@ func (p *HostingProxy) getOrCreateProxy(deployment *NativeDeployment) *httputil.
 			log.Printf("Proxy error for %s: %v", deployment.Subdomain, err)
 			p.serveError(w, r, "Deployment temporarily unavailable")
 		},
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
+		Transport: transport,
 	}
 
 	// Cache the proxy
-	p.proxyCache.Store(deployment.ID, proxy)
+	p.proxyCache.Store(cacheKey, proxy)
 
 	return proxy
 }
 
-// getTargetURL returns the internal URL for the deployment's container
-func (p *HostingProxy) getTargetURL(deployment *NativeDeployment) *url.URL {
+// getTargetURL returns the internal URL for a deployment instance's
+// container. Instance 0 is the primary container; further instances are
+// named with an -N suffix.
+func (p *HostingProxy) getTargetURL(deployment *NativeDeployment, instance int) *url.URL {
 	// In production, this would be the container's internal network address
 	// For Docker, it might be: http://apex-{deploymentID}:3000
 	// For Kubernetes, it might be: http://{serviceName}.{namespace}.svc.cluster.local:3000
@@ -247,7 +380,7 @@ func (p *HostingProxy) getTargetURL(deployment *NativeDeployment) *url.URL {
 	var host string
 	if deployment.ContainerID != "" {
 		// Docker networking
-		host = deployment.ContainerID
+		host = instanceName(deployment.ContainerID, instance)
 	} else {
 		// Fallback to localhost for development
 		host = "localhost"
@@ -534,14 +667,24 @@ func (p *HostingProxy) InvalidateCache(subdomain string) {
 	// Also invalidate the proxy
 	if cached, ok := p.routeCache.Load(subdomain); ok {
 		if entry, ok := cached.(*cacheEntry); ok && entry.deployment != nil {
-			p.proxyCache.Delete(entry.deployment.ID)
+			p.InvalidateDeploymentCache(entry.deployment.ID)
 		}
 	}
 }
 
-// InvalidateDeploymentCache removes a deployment from the cache by ID
+// InvalidateDeploymentCache removes a deployment's instance proxies from the cache by ID
 func (p *HostingProxy) InvalidateDeploymentCache(deploymentID string) {
-	p.proxyCache.Delete(deploymentID)
+	p.proxyCache.Range(func(key, value interface{}) bool {
+		if k, ok := key.(string); ok && (k == deploymentID || strings.HasPrefix(k, deploymentID+"#")) {
+			p.proxyCache.Delete(key)
+		}
+		return true
+	})
+}
+
+// ActiveWebSockets returns the number of open WebSocket tunnels for a deployment
+func (p *HostingProxy) ActiveWebSockets(deploymentID string) int {
+	return p.wsLimiter.Active(deploymentID)
 }
 
 // HealthCheckHandler returns a handler for the hosting proxy health check
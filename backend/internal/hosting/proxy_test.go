@@ -0,0 +1,151 @@
+package hosting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+func setupProxyTest(t *testing.T, maxConns int) (*HostingProxy, string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&NativeDeployment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	upgrader := websocket.Upgrader{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-Host") != r.Host {
+			http.Error(w, "host not preserved", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(kind, msg); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	deployment := &NativeDeployment{
+		ID:            "proxy-test-deployment",
+		ProjectID:     1,
+		UserID:        1,
+		Subdomain:     "socket-app",
+		Status:        StatusRunning,
+		ContainerID:   backendURL.Hostname(),
+		ContainerPort: port,
+	}
+	if err := db.Create(deployment).Error; err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+
+	proxy := NewHostingProxy(db, &ProxyConfig{HostingDomain: "apex.test", MaxWebSocketConns: maxConns})
+	// A short server ReadTimeout proves the tunnel is not bound by it.
+	front := httptest.NewUnstartedServer(proxy)
+	front.Config.ReadTimeout = 100 * time.Millisecond
+	front.Start()
+	t.Cleanup(front.Close)
+	return proxy, "ws" + strings.TrimPrefix(front.URL, "http")
+}
+
+func dialProxy(wsURL string) (*websocket.Conn, *http.Response, error) {
+	// Dial the proxy's address but present the deployment's hostname.
+	header := http.Header{}
+	header.Set("Host", "socket-app.apex.test")
+	dialer := websocket.Dialer{HandshakeTimeout: 2 * time.Second}
+	return dialer.Dial(wsURL, header)
+}
+
+func TestProxyTunnelsWebSocketsPastServerTimeouts(t *testing.T) {
+	proxy, wsURL := setupProxyTest(t, 1)
+
+	conn, _, err := dialProxy(wsURL)
+	if err != nil {
+		t.Fatalf("dial through proxy: %v", err)
+	}
+	defer conn.Close()
+
+	time.Sleep(250 * time.Millisecond)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil || string(msg) != "ping" {
+		t.Fatalf("echo = %q, %v", msg, err)
+	}
+	if got := proxy.ActiveWebSockets("proxy-test-deployment"); got != 1 {
+		t.Fatalf("ActiveWebSockets = %d, want 1", got)
+	}
+
+	_, resp, err := dialProxy(wsURL)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("connection over the limit should get 503, resp = %v, err = %v", resp, err)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Fatal("limit response should set Retry-After")
+	}
+}
+
+func TestSelectInstanceStickyForAlwaysOn(t *testing.T) {
+	proxy := NewHostingProxy(nil, &ProxyConfig{HostingDomain: "apex.test"})
+	single := &NativeDeployment{ID: "d", CurrentInstances: 1, AlwaysOn: true}
+	if instance, pin := proxy.selectInstance(httptest.NewRequest(http.MethodGet, "/", nil), single); instance != 0 || pin {
+		t.Fatalf("single instance = %d, pin = %v", instance, pin)
+	}
+
+	scaled := &NativeDeployment{ID: "d", CurrentInstances: 3, AlwaysOn: true}
+	first, pin := proxy.selectInstance(httptest.NewRequest(http.MethodGet, "/", nil), scaled)
+	if !pin {
+		t.Fatal("new client of an always-on deployment should be pinned")
+	}
+	pinned := httptest.NewRequest(http.MethodGet, "/", nil)
+	pinned.AddCookie(&http.Cookie{Name: StickyCookieName, Value: strconv.Itoa(first)})
+	for i := 0; i < 5; i++ {
+		if instance, pin := proxy.selectInstance(pinned, scaled); instance != first || pin {
+			t.Fatalf("pinned request went to %d (pin %v), want %d", instance, pin, first)
+		}
+	}
+
+	stale := httptest.NewRequest(http.MethodGet, "/", nil)
+	stale.AddCookie(&http.Cookie{Name: StickyCookieName, Value: "7"})
+	if instance, pin := proxy.selectInstance(stale, scaled); instance >= 3 || !pin {
+		t.Fatalf("out-of-range pin = %d, repin = %v", instance, pin)
+	}
+
+	scaled.AlwaysOn = false
+	seen := map[int]bool{}
+	for i := 0; i < 3; i++ {
+		instance, pin := proxy.selectInstance(pinned, scaled)
+		if pin {
+			t.Fatal("on-demand deployments should not be pinned")
+		}
+		seen[instance] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("round robin reached %d instances, want 3", len(seen))
+	}
+}
@@ -0,0 +1,461 @@
+// Package hosting - Health-checked zero-downtime restarts
+// A restart starts a replacement container next to the running one, waits
+// for the replacement's health endpoint, switches traffic to it and only
+// then stops the old container. Automatic restarts back off after failures
+// and stop when a deployment is crash looping.
+package hosting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Restart reasons recorded with each restart event
+const (
+	RestartManual       = "manual"
+	RestartCrashed      = "crashed"
+	RestartStopped      = "stopped"
+	RestartUnhealthy    = "unhealthy"
+	RestartConfigChange = "config_change"
+	RestartTokenRotated = "token_rotated"
+)
+
+// Deployment event types written by the restart controller
+const (
+	EventRestart   = "restart"
+	EventCrashLoop = "crash_loop"
+)
+
+const (
+	// CrashLoopThreshold automatic restarts within CrashLoopWindow mark a
+	// deployment as crash looping; it is then left stopped until someone
+	// restarts or redeploys it
+	CrashLoopThreshold = 5
+	CrashLoopWindow    = 10 * time.Minute
+
+	restartBackoffBase = 10 * time.Second
+	restartBackoffMax  = 5 * time.Minute
+	// replacementHealthDeadline bounds how long a replacement container has
+	// to pass its health check
+	replacementHealthDeadline = 2 * time.Minute
+	// defaultRestartDrain lets requests in flight on the old container
+	// finish before it is stopped
+	defaultRestartDrain = 10 * time.Second
+
+	maxHealthCheckTimeout  = 60
+	minHealthCheckInterval = 10
+	maxHealthCheckInterval = 300
+)
+
+var (
+	// ErrRestartInProgress is returned when a deployment is already
+	// restarting or scaling
+	ErrRestartInProgress = errors.New("deployment is already restarting or scaling")
+	// ErrInvalidHealthCheck wraps health check validation failures
+	ErrInvalidHealthCheck = errors.New("invalid health check")
+)
+
+// ContainerRuntime starts and stops deployment containers
+type ContainerRuntime interface {
+	// StartReplacement starts a container named name from the image and
+	// environment of the deployment's current container, with env applied
+	// on top of that environment
+	StartReplacement(ctx context.Context, deployment *NativeDeployment, name string, env map[string]string) error
+	// Stop stops and removes a container
+	Stop(ctx context.Context, name string) error
+}
+
+// HealthProbe requests a health URL and returns the status code
+type HealthProbe func(ctx context.Context, url string) (int, error)
+
+// RestartDetails is the metadata of a restart event
+type RestartDetails struct {
+	Reason       string `json:"reason"`
+	Automatic    bool   `json:"automatic"`
+	Attempt      int    `json:"attempt,omitempty"`
+	OldContainer string `json:"old_container,omitempty"`
+	NewContainer string `json:"new_container,omitempty"`
+	HealthURL    string `json:"health_url,omitempty"`
+	HealthStatus int    `json:"health_status,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// HealthCheckConfig is the health endpoint restarts and monitors probe
+type HealthCheckConfig struct {
+	Path            string `json:"path"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+// SetContainerRuntime replaces the Docker CLI runtime used for restarts
+func (s *HostingService) SetContainerRuntime(runtime ContainerRuntime) {
+	s.runtime = runtime
+}
+
+// SetHealthProbe replaces the HTTP probe used to check replacement containers
+func (s *HostingService) SetHealthProbe(probe HealthProbe) {
+	s.probe = probe
+}
+
+// SetRestartDrain sets how long the old container keeps running after
+// traffic moves to its replacement
+func (s *HostingService) SetRestartDrain(drain time.Duration) {
+	s.restartDrain = drain
+}
+
+// UpdateHealthCheck validates and stores a deployment's health check
+func (s *HostingService) UpdateHealthCheck(deploymentID string, config HealthCheckConfig) (*NativeDeployment, error) {
+	deployment, err := s.GetDeployment(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if config.Path == "" {
+		config.Path = "/health"
+	}
+	if !strings.HasPrefix(config.Path, "/") || len(config.Path) > 255 || strings.ContainsAny(config.Path, " \t\r\n#") {
+		return nil, fmt.Errorf("%w: path must be an absolute URL path", ErrInvalidHealthCheck)
+	}
+	if config.TimeoutSeconds == 0 {
+		config.TimeoutSeconds = 5
+	}
+	if config.TimeoutSeconds < 1 || config.TimeoutSeconds > maxHealthCheckTimeout {
+		return nil, fmt.Errorf("%w: timeout_seconds must be between 1 and %d", ErrInvalidHealthCheck, maxHealthCheckTimeout)
+	}
+	if config.IntervalSeconds == 0 {
+		config.IntervalSeconds = 30
+	}
+	if config.IntervalSeconds < minHealthCheckInterval || config.IntervalSeconds > maxHealthCheckInterval {
+		return nil, fmt.Errorf("%w: interval_seconds must be between %d and %d", ErrInvalidHealthCheck, minHealthCheckInterval, maxHealthCheckInterval)
+	}
+
+	deployment.HealthCheckPath = config.Path
+	deployment.HealthCheckTimeout = config.TimeoutSeconds
+	deployment.HealthCheckInterval = config.IntervalSeconds
+	if err := s.db.Model(deployment).Updates(map[string]interface{}{
+		"health_check_path":     config.Path,
+		"health_check_timeout":  config.TimeoutSeconds,
+		"health_check_interval": config.IntervalSeconds,
+	}).Error; err != nil {
+		return nil, err
+	}
+	s.addLog(deploymentID, "info", "health", fmt.Sprintf("Health check set to %s (timeout %ds)", config.Path, config.TimeoutSeconds))
+	return deployment, nil
+}
+
+// GetDeploymentEvents returns a deployment's lifecycle events, newest
+// first, optionally filtered by type
+func (s *HostingService) GetDeploymentEvents(deploymentID, eventType string, limit int) ([]DeploymentEvent, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := s.db.Where("deployment_id = ?", deploymentID)
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	var events []DeploymentEvent
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// recordRestartEvent stores a restart in the deployment's event history
+func (s *HostingService) recordRestartEvent(deploymentID, eventType, status, message string, details RestartDetails, duration time.Duration) {
+	metadata, _ := json.Marshal(details)
+	s.db.Create(&DeploymentEvent{
+		DeploymentID: deploymentID,
+		EventType:    eventType,
+		EventStatus:  status,
+		Message:      message,
+		Metadata:     string(metadata),
+		Duration:     duration.Milliseconds(),
+	})
+}
+
+// restartDeployment replaces the deployment's containers without dropping
+// traffic. The old containers keep serving until every replacement is
+// healthy; if a replacement never becomes healthy the replacements are
+// removed and the old containers are left in place.
+func (s *HostingService) restartDeployment(ctx context.Context, deployment *NativeDeployment, details RestartDetails) error {
+	if _, busy := s.containerOps.LoadOrStore(deployment.ID, true); busy {
+		return ErrRestartInProgress
+	}
+	defer s.containerOps.Delete(deployment.ID)
+
+	started := time.Now()
+	oldContainer := deployment.ContainerID
+	newContainer := fmt.Sprintf("apex-%s-%s", deployment.ID[:12], generateRandomSuffix(6))
+	details.OldContainer = oldContainer
+	details.NewContainer = newContainer
+	details.HealthURL = healthURL(deployment, newContainer)
+
+	var startedContainers []string
+	fail := func(err error) error {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		for _, name := range startedContainers {
+			_ = s.runtime.Stop(stopCtx, name)
+		}
+		cancel()
+		details.Error = err.Error()
+		s.addLog(deployment.ID, "error", "runtime", fmt.Sprintf("Restart failed: %v", err))
+		s.recordRestartEvent(deployment.ID, EventRestart, "error",
+			fmt.Sprintf("Restart (%s) failed: %v", details.Reason, err), details, time.Since(started))
+		return err
+	}
+
+	// Every instance is replaced, so the proxy's instance names stay valid
+	// once traffic moves to the new primary
+	env := s.replacementEnv(deployment)
+	for i := 0; i < instanceCount(deployment); i++ {
+		name := instanceName(newContainer, i)
+		s.addLog(deployment.ID, "info", "runtime", fmt.Sprintf("Starting replacement container %s...", name))
+		if err := s.runtime.StartReplacement(ctx, deployment, name, env); err != nil {
+			return fail(fmt.Errorf("start replacement: %w", err))
+		}
+		startedContainers = append(startedContainers, name)
+
+		status, err := s.waitForReplacement(ctx, deployment, healthURL(deployment, name))
+		if i == 0 {
+			details.HealthStatus = status
+		}
+		if err != nil {
+			return fail(fmt.Errorf("replacement failed its health check at %s: %w", deployment.HealthCheckPath, err))
+		}
+	}
+
+	// Switch traffic: the proxy addresses the deployment's containers by name
+	now := time.Now()
+	deployment.ContainerID = newContainer
+	deployment.ContainerStatus = ContainerHealthy
+	deployment.LastHealthCheck = &now
+	s.db.Model(deployment).Updates(map[string]interface{}{
+		"container_id":      newContainer,
+		"container_status":  ContainerHealthy,
+		"last_health_check": &now,
+	})
+	s.containerMgr.mu.Lock()
+	s.containerMgr.containers[deployment.ID] = newContainer
+	s.containerMgr.mu.Unlock()
+	s.mu.Lock()
+	if active, ok := s.activeDeployments[deployment.ID]; ok {
+		active.ContainerID = newContainer
+		active.ContainerStatus = ContainerHealthy
+	}
+	s.mu.Unlock()
+	s.addLog(deployment.ID, "info", "runtime", fmt.Sprintf("Traffic moved to %s", newContainer))
+
+	if oldContainer != "" {
+		if s.restartDrain > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.restartDrain):
+			}
+		}
+		stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		for i := 0; i < instanceCount(deployment); i++ {
+			name := instanceName(oldContainer, i)
+			if err := s.runtime.Stop(stopCtx, name); err != nil {
+				s.addLog(deployment.ID, "warn", "runtime", fmt.Sprintf("Failed to stop old container %s: %v", name, err))
+			}
+		}
+		cancel()
+	}
+
+	s.recordRestartEvent(deployment.ID, EventRestart, "success",
+		fmt.Sprintf("Restarted (%s): replacement healthy after %s", details.Reason, time.Since(started).Round(100*time.Millisecond)),
+		details, time.Since(started))
+	s.addLog(deployment.ID, "info", "runtime", "Deployment restarted successfully")
+	return nil
+}
+
+// storedEnvVars returns the env vars set through the API, which a
+// replacement applies over the old container's environment
+func (s *HostingService) storedEnvVars(deploymentID string) map[string]string {
+	var vars []DeploymentEnvVar
+	s.db.Where("deployment_id = ?", deploymentID).Find(&vars)
+	env := make(map[string]string, len(vars))
+	for _, v := range vars {
+		env[v.Key] = v.Value
+	}
+	return env
+}
+
+// replacementEnv is what a replacement applies over the old container's
+// environment: the stored env vars and the project's current service token
+func (s *HostingService) replacementEnv(deployment *NativeDeployment) map[string]string {
+	env := s.storedEnvVars(deployment.ID)
+	for key, value := range s.projectTokenEnv(deployment.ProjectID) {
+		env[key] = value
+	}
+	return env
+}
+
+// waitForReplacement probes the replacement until it answers 2xx or 3xx
+func (s *HostingService) waitForReplacement(ctx context.Context, deployment *NativeDeployment, url string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, replacementHealthDeadline)
+	defer cancel()
+
+	timeout := time.Duration(deployment.HealthCheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	var lastStatus int
+	var lastErr error
+	for {
+		probeCtx, probeCancel := context.WithTimeout(ctx, timeout)
+		status, err := s.probe(probeCtx, url)
+		probeCancel()
+		if err == nil && status >= 200 && status < 400 {
+			return status, nil
+		}
+		lastStatus, lastErr = status, err
+		if lastErr == nil {
+			lastErr = fmt.Errorf("status %d", status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastStatus, lastErr
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// healthURL is the replacement's health endpoint on the container network
+func healthURL(deployment *NativeDeployment, container string) string {
+	port := deployment.ContainerPort
+	if port == 0 {
+		port = 3000
+	}
+	path := deployment.HealthCheckPath
+	if path == "" {
+		path = "/health"
+	}
+	return fmt.Sprintf("http://%s:%d%s", container, port, path)
+}
+
+// restartBackoff is how long to wait after the given number of consecutive
+// failed restarts
+func restartBackoff(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	backoff := restartBackoffBase
+	for i := 1; i < failures && backoff < restartBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > restartBackoffMax {
+		backoff = restartBackoffMax
+	}
+	return backoff
+}
+
+// recentAutomaticRestarts counts automatic restarts within CrashLoopWindow
+func (s *HostingService) recentAutomaticRestarts(deploymentID string, now time.Time) int {
+	var events []DeploymentEvent
+	s.db.Where("deployment_id = ? AND event_type = ? AND created_at > ?", deploymentID, EventRestart, now.Add(-CrashLoopWindow)).
+		Find(&events)
+	count := 0
+	for _, event := range events {
+		var details RestartDetails
+		if json.Unmarshal([]byte(event.Metadata), &details) == nil && details.Automatic {
+			count++
+		}
+	}
+	return count
+}
+
+// markCrashLoop stops automatic restarts for the deployment
+func (s *HostingService) markCrashLoop(deployment *NativeDeployment, restarts int, reason string) {
+	now := time.Now()
+	message := fmt.Sprintf("Crash loop detected: %d automatic restarts in %s; automatic restarts paused until the deployment is restarted or redeployed",
+		restarts, CrashLoopWindow)
+	deployment.CrashLoopDetectedAt = &now
+	s.db.Model(deployment).Update("crash_loop_detected_at", &now)
+	s.updateStatus(deployment, StatusFailed, message)
+	s.addLog(deployment.ID, "error", "always-on", message)
+	s.recordRestartEvent(deployment.ID, EventCrashLoop, "error", message,
+		RestartDetails{Reason: reason, Automatic: true, Attempt: restarts}, 0)
+}
+
+// dockerRuntime runs restarts through the Docker CLI
+type dockerRuntime struct {
+	networkName string
+}
+
+// StartReplacement starts name from the image and environment of the
+// deployment's current container. Replacements don't publish a host port;
+// the proxy reaches them over the container network.
+func (r *dockerRuntime) StartReplacement(ctx context.Context, deployment *NativeDeployment, name string, overrides map[string]string) error {
+	image := fmt.Sprintf("apex/%s:%s", deployment.Subdomain, deployment.ID[:8])
+	var env []string
+	if deployment.ContainerID != "" {
+		output, err := exec.CommandContext(ctx, "docker", "inspect", "--format", "{{json .Config}}", deployment.ContainerID).Output()
+		if err != nil {
+			return fmt.Errorf("inspect %s: %w", deployment.ContainerID, err)
+		}
+		var config struct {
+			Image string   `json:"Image"`
+			Env   []string `json:"Env"`
+		}
+		if err := json.Unmarshal(output, &config); err != nil {
+			return fmt.Errorf("inspect %s: %w", deployment.ContainerID, err)
+		}
+		image, env = config.Image, config.Env
+	}
+
+	args := []string{
+		"run", "-d",
+		"--name", name,
+		"--restart", "unless-stopped",
+		"--network", r.networkName,
+		"-m", fmt.Sprintf("%dm", deployment.MemoryLimit),
+		"--cpus", fmt.Sprintf("%.2f", float64(deployment.CPULimit)/1000),
+	}
+	for _, e := range env {
+		if key, _, _ := strings.Cut(e, "="); overrides[key] == "" {
+			args = append(args, "-e", e)
+		}
+	}
+	for key, value := range overrides {
+		if value != "" {
+			args = append(args, "-e", key+"="+value)
+		}
+	}
+	args = append(args, image)
+	if output, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker run: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Stop stops and removes a container
+func (r *dockerRuntime) Stop(ctx context.Context, name string) error {
+	if output, err := exec.CommandContext(ctx, "docker", "rm", "--force", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker rm: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// httpHealthProbe is the default HealthProbe
+func httpHealthProbe(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "APEX-Health-Check/1.0")
+	client := &http.Client{
+		// A redirect is a healthy answer; don't follow it off the container
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
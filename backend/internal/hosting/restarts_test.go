@@ -0,0 +1,192 @@
+package hosting
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeRuntime records container starts and stops in order
+type fakeRuntime struct {
+	mu        sync.Mutex
+	failStart bool
+	calls     []string
+}
+
+func (f *fakeRuntime) StartReplacement(_ context.Context, _ *NativeDeployment, name string, _ map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "start "+name)
+	if f.failStart {
+		return errors.New("image not found")
+	}
+	return nil
+}
+
+func (f *fakeRuntime) Stop(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "stop "+name)
+	return nil
+}
+
+func setupRestartTest(t *testing.T, probe HealthProbe) (*HostingService, *gorm.DB, *NativeDeployment, *fakeRuntime) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&NativeDeployment{}, &DeploymentLog{}, &DeploymentEnvVar{}, &DeploymentEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewHostingService(db)
+	t.Cleanup(svc.Close)
+	runtime := &fakeRuntime{}
+	svc.SetContainerRuntime(runtime)
+	svc.SetHealthProbe(probe)
+	svc.SetRestartDrain(0)
+
+	deployment := &NativeDeployment{
+		ID:              "abcdef0123456789abcdef0123456789abcd",
+		ProjectID:       7,
+		UserID:          3,
+		Subdomain:       "restart-app",
+		Status:          StatusRunning,
+		ContainerID:     "apex-abcdef012345",
+		ContainerPort:   8080,
+		HealthCheckPath: "/ready",
+		AlwaysOn:        true,
+		MaxRestarts:     10,
+	}
+	if err := db.Create(deployment).Error; err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+	return svc, db, deployment, runtime
+}
+
+func TestRestartSwitchesTrafficOnlyAfterReplacementIsHealthy(t *testing.T) {
+	var probed []string
+	svc, db, deployment, runtime := setupRestartTest(t, func(_ context.Context, url string) (int, error) {
+		probed = append(probed, url)
+		return 200, nil
+	})
+
+	if err := svc.RestartDeployment(deployment.ID); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	var stored NativeDeployment
+	db.First(&stored, "id = ?", deployment.ID)
+	if stored.ContainerID == "apex-abcdef012345" || !strings.HasPrefix(stored.ContainerID, "apex-abcdef012345-") {
+		t.Fatalf("container = %s", stored.ContainerID)
+	}
+	want := []string{"start " + stored.ContainerID, "stop apex-abcdef012345"}
+	if strings.Join(runtime.calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("calls = %v, want %v", runtime.calls, want)
+	}
+	if len(probed) != 1 || probed[0] != "http://"+stored.ContainerID+":8080/ready" {
+		t.Fatalf("probed = %v", probed)
+	}
+
+	events, _ := svc.GetDeploymentEvents(deployment.ID, EventRestart, 10)
+	if len(events) != 1 || events[0].EventStatus != "success" || !strings.Contains(events[0].Metadata, `"reason":"manual"`) {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestRestartKeepsOldContainerWhenReplacementIsUnhealthy(t *testing.T) {
+	svc, db, deployment, runtime := setupRestartTest(t, func(context.Context, string) (int, error) {
+		return 503, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := svc.restartDeployment(ctx, deployment, RestartDetails{Reason: RestartConfigChange})
+	if err == nil || !strings.Contains(err.Error(), "/ready") {
+		t.Fatalf("err = %v", err)
+	}
+	var stored NativeDeployment
+	db.First(&stored, "id = ?", deployment.ID)
+	if stored.ContainerID != "apex-abcdef012345" {
+		t.Fatalf("traffic moved to an unhealthy container: %s", stored.ContainerID)
+	}
+	if len(runtime.calls) != 2 || !strings.HasPrefix(runtime.calls[1], "stop apex-abcdef012345-") {
+		t.Fatalf("the replacement should be removed, not the old container: %v", runtime.calls)
+	}
+	events, _ := svc.GetDeploymentEvents(deployment.ID, "", 10)
+	if len(events) != 1 || events[0].EventStatus != "error" || !strings.Contains(events[0].Metadata, `"health_status":503`) {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestAutoRestartBacksOffAndDetectsCrashLoops(t *testing.T) {
+	svc, db, deployment, runtime := setupRestartTest(t, func(context.Context, string) (int, error) {
+		return 200, nil
+	})
+	runtime.failStart = true
+	deployment.Status = StatusFailed
+
+	svc.autoRestartDeployment(deployment, RestartCrashed)
+	if deployment.NextRestartAt == nil || time.Until(*deployment.NextRestartAt) < 5*time.Second {
+		t.Fatalf("failed restart should back off: %+v", deployment.NextRestartAt)
+	}
+	// Within the backoff nothing is attempted
+	svc.autoRestartDeployment(deployment, RestartCrashed)
+	if len(runtime.calls) != 1 {
+		t.Fatalf("calls = %v", runtime.calls)
+	}
+	if restartBackoff(1) != 10*time.Second || restartBackoff(3) != 40*time.Second || restartBackoff(20) != restartBackoffMax {
+		t.Fatal("unexpected backoff schedule")
+	}
+
+	// Automatic restarts that keep succeeding and crashing again are a
+	// loop; the failed attempt above counts toward it
+	runtime.failStart = false
+	deployment.NextRestartAt = nil
+	for i := 1; i < CrashLoopThreshold; i++ {
+		svc.autoRestartDeployment(deployment, RestartCrashed)
+	}
+	if deployment.CrashLoopDetectedAt != nil {
+		t.Fatal("crash loop detected too early")
+	}
+	svc.autoRestartDeployment(deployment, RestartCrashed)
+
+	var stored NativeDeployment
+	db.First(&stored, "id = ?", deployment.ID)
+	if stored.CrashLoopDetectedAt == nil || stored.Status != StatusFailed || stored.NeedsCrashRecovery() {
+		t.Fatalf("deployment = %+v", stored)
+	}
+	loops, _ := svc.GetDeploymentEvents(deployment.ID, EventCrashLoop, 10)
+	if len(loops) != 1 {
+		t.Fatalf("crash loop events = %+v", loops)
+	}
+
+	// A manual restart clears the crash loop
+	if err := svc.RestartDeployment(deployment.ID); err != nil {
+		t.Fatalf("manual restart: %v", err)
+	}
+	var restarted NativeDeployment
+	db.First(&restarted, "id = ?", deployment.ID)
+	if restarted.CrashLoopDetectedAt != nil || restarted.Status != StatusRunning {
+		t.Fatalf("after manual restart = %+v", restarted)
+	}
+}
+
+func TestUpdateHealthCheckValidates(t *testing.T) {
+	svc, _, deployment, _ := setupRestartTest(t, nil)
+	if _, err := svc.UpdateHealthCheck(deployment.ID, HealthCheckConfig{Path: "healthz"}); !errors.Is(err, ErrInvalidHealthCheck) {
+		t.Fatalf("relative path err = %v", err)
+	}
+	if _, err := svc.UpdateHealthCheck(deployment.ID, HealthCheckConfig{Path: "/healthz", TimeoutSeconds: 120}); !errors.Is(err, ErrInvalidHealthCheck) {
+		t.Fatalf("timeout err = %v", err)
+	}
+	updated, err := svc.UpdateHealthCheck(deployment.ID, HealthCheckConfig{Path: "/healthz"})
+	if err != nil || updated.HealthCheckPath != "/healthz" || updated.HealthCheckTimeout != 5 || updated.HealthCheckInterval != 30 {
+		t.Fatalf("updated = %+v, %v", updated, err)
+	}
+}
@@ -0,0 +1,407 @@
+// Package hosting - Security headers for hosted apps
+// Each deployment can configure the CSP, HSTS, frame-ancestors,
+// Permissions-Policy and Referrer-Policy headers the proxy adds. Unset
+// fields fall back to defaults generated for the deployment's framework.
+package hosting
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// SecurityHeaderOff disables a header instead of using its default
+const SecurityHeaderOff = "off"
+
+// cspReportGroup names the Reporting API endpoint CSP violations go to
+const cspReportGroup = "apex-csp"
+
+// ErrInvalidSecurityHeaders wraps invalid security header configuration
+var ErrInvalidSecurityHeaders = errors.New("invalid security headers")
+
+// SecurityHeaders configures the security headers of a deployment's
+// responses. Empty fields use the framework defaults; SecurityHeaderOff
+// drops the header.
+type SecurityHeaders struct {
+	ContentSecurityPolicy   string   `json:"content_security_policy,omitempty"`
+	CSPReportOnly           bool     `json:"csp_report_only,omitempty"`
+	StrictTransportSecurity string   `json:"strict_transport_security,omitempty"`
+	FrameAncestors          []string `json:"frame_ancestors,omitempty"` // CSP sources, e.g. 'self' or https://example.com
+	PermissionsPolicy       string   `json:"permissions_policy,omitempty"`
+	ReferrerPolicy          string   `json:"referrer_policy,omitempty"`
+	// Stop browsers reporting CSP violations to the platform
+	DisableViolationReports bool `json:"disable_violation_reports,omitempty"`
+}
+
+// DefaultSecurityHeaders returns the headers generated for a framework.
+// Server-rendered frameworks inline hydration data and templates often
+// inline scripts, so only static single-page app builds get a script-src
+// without 'unsafe-inline'.
+func DefaultSecurityHeaders(framework string) SecurityHeaders {
+	scriptSrc := []string{"'self'", "'unsafe-inline'"}
+	styleSrc := []string{"'self'", "'unsafe-inline'"}
+	imgSrc := []string{"'self'", "data:", "blob:", "https:"}
+
+	switch strings.ToLower(strings.TrimSpace(framework)) {
+	case "vite", "react", "vue", "svelte", "angular", "static":
+		scriptSrc = []string{"'self'"}
+	case "fastapi":
+		// The interactive docs load Swagger UI and ReDoc from a CDN
+		scriptSrc = append(scriptSrc, "https://cdn.jsdelivr.net")
+		styleSrc = append(styleSrc, "https://cdn.jsdelivr.net")
+	}
+
+	policy := []string{
+		"default-src 'self'",
+		"script-src " + strings.Join(scriptSrc, " "),
+		"style-src " + strings.Join(styleSrc, " "),
+		"img-src " + strings.Join(imgSrc, " "),
+		"font-src 'self' data: https:",
+		"connect-src 'self' https: wss:",
+		"media-src 'self' blob: https:",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+	}
+	return SecurityHeaders{
+		ContentSecurityPolicy:   strings.Join(policy, "; "),
+		StrictTransportSecurity: "max-age=31536000; includeSubDomains",
+		FrameAncestors:          []string{"'self'"},
+		PermissionsPolicy:       "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+	}
+}
+
+// Resolved fills unset fields from the framework defaults. A nil
+// configuration resolves to the defaults.
+func (h *SecurityHeaders) Resolved(framework string) SecurityHeaders {
+	resolved := DefaultSecurityHeaders(framework)
+	if h == nil {
+		return resolved
+	}
+	if h.ContentSecurityPolicy != "" {
+		resolved.ContentSecurityPolicy = h.ContentSecurityPolicy
+		// A policy's own frame-ancestors wins over the default
+		if ancestors, ok := cspDirectiveValues(h.ContentSecurityPolicy, "frame-ancestors"); ok {
+			resolved.FrameAncestors = ancestors
+		}
+	}
+	if h.StrictTransportSecurity != "" {
+		resolved.StrictTransportSecurity = h.StrictTransportSecurity
+	}
+	if len(h.FrameAncestors) > 0 {
+		resolved.FrameAncestors = h.FrameAncestors
+	}
+	if h.PermissionsPolicy != "" {
+		resolved.PermissionsPolicy = h.PermissionsPolicy
+	}
+	if h.ReferrerPolicy != "" {
+		resolved.ReferrerPolicy = h.ReferrerPolicy
+	}
+	resolved.CSPReportOnly = h.CSPReportOnly
+	resolved.DisableViolationReports = h.DisableViolationReports
+	return resolved
+}
+
+// IsZero reports whether nothing is configured
+func (h *SecurityHeaders) IsZero() bool {
+	return h == nil || (h.ContentSecurityPolicy == "" && !h.CSPReportOnly && h.StrictTransportSecurity == "" &&
+		len(h.FrameAncestors) == 0 && h.PermissionsPolicy == "" && h.ReferrerPolicy == "" && !h.DisableViolationReports)
+}
+
+// Validate checks the configuration's header syntax
+func (h *SecurityHeaders) Validate() error {
+	if h == nil {
+		return nil
+	}
+	for name, value := range map[string]string{
+		"content_security_policy":   h.ContentSecurityPolicy,
+		"strict_transport_security": h.StrictTransportSecurity,
+		"permissions_policy":        h.PermissionsPolicy,
+		"referrer_policy":           h.ReferrerPolicy,
+	} {
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("%w: %s contains control characters", ErrInvalidSecurityHeaders, name)
+		}
+	}
+
+	if csp := h.ContentSecurityPolicy; csp != "" && csp != SecurityHeaderOff {
+		if err := ValidateCSP(csp); err != nil {
+			return err
+		}
+	}
+	if err := validateSourceList("frame-ancestors", h.FrameAncestors); err != nil {
+		return err
+	}
+	if hsts := h.StrictTransportSecurity; hsts != "" && hsts != SecurityHeaderOff && !hstsPattern.MatchString(hsts) {
+		return fmt.Errorf("%w: strict_transport_security must look like \"max-age=31536000; includeSubDomains\"", ErrInvalidSecurityHeaders)
+	}
+	if policy := h.PermissionsPolicy; policy != "" && policy != SecurityHeaderOff {
+		for _, item := range strings.Split(policy, ",") {
+			if !permissionsPolicyItem.MatchString(strings.TrimSpace(item)) {
+				return fmt.Errorf("%w: invalid permissions_policy entry %q", ErrInvalidSecurityHeaders, strings.TrimSpace(item))
+			}
+		}
+	}
+	if referrer := h.ReferrerPolicy; referrer != "" && referrer != SecurityHeaderOff && !referrerPolicies[referrer] {
+		return fmt.Errorf("%w: unknown referrer_policy %q", ErrInvalidSecurityHeaders, referrer)
+	}
+	return nil
+}
+
+// Apply adds the deployment's security headers to a response. Configured
+// values replace the app's own headers; generated defaults only fill
+// headers the app did not set. reportURI, when not empty, receives CSP
+// violation reports.
+func (h *SecurityHeaders) Apply(header http.Header, framework, reportURI string) {
+	resolved := h.Resolved(framework)
+	configured := h
+	if configured == nil {
+		configured = &SecurityHeaders{}
+	}
+
+	set := func(name, value string, explicit bool) {
+		switch {
+		case value == SecurityHeaderOff:
+			if explicit {
+				header.Del(name)
+			}
+		case explicit || header.Get(name) == "":
+			header.Set(name, value)
+		}
+	}
+
+	if csp := resolved.ContentSecurityPolicy; csp != SecurityHeaderOff {
+		csp = setCSPDirective(csp, "frame-ancestors", strings.Join(resolved.FrameAncestors, " "))
+		if reportURI != "" && !resolved.DisableViolationReports {
+			if !hasCSPDirective(csp, "report-uri") {
+				csp += "; report-uri " + reportURI
+			}
+			if !hasCSPDirective(csp, "report-to") {
+				csp += "; report-to " + cspReportGroup
+			}
+		}
+		name := "Content-Security-Policy"
+		if resolved.CSPReportOnly {
+			name = "Content-Security-Policy-Report-Only"
+		}
+		explicit := configured.ContentSecurityPolicy != "" || len(configured.FrameAncestors) > 0
+		if explicit || (header.Get("Content-Security-Policy") == "" && header.Get("Content-Security-Policy-Report-Only") == "") {
+			header.Set(name, csp)
+			if reportURI != "" && !resolved.DisableViolationReports && strings.Contains(csp, "report-to "+cspReportGroup) {
+				header.Set("Reporting-Endpoints", fmt.Sprintf("%s=%q", cspReportGroup, reportURI))
+			}
+		}
+	}
+
+	// X-Frame-Options covers browsers without frame-ancestors, and still
+	// applies when the CSP is report-only
+	if frameOptions := frameOptionsFor(resolved.FrameAncestors); frameOptions != "" {
+		set("X-Frame-Options", frameOptions, len(configured.FrameAncestors) > 0)
+	} else if len(configured.FrameAncestors) > 0 {
+		header.Del("X-Frame-Options")
+	}
+	set("Strict-Transport-Security", resolved.StrictTransportSecurity, configured.StrictTransportSecurity != "")
+	set("Permissions-Policy", resolved.PermissionsPolicy, configured.PermissionsPolicy != "")
+	set("Referrer-Policy", resolved.ReferrerPolicy, configured.ReferrerPolicy != "")
+}
+
+func frameOptionsFor(ancestors []string) string {
+	if len(ancestors) != 1 {
+		return ""
+	}
+	switch ancestors[0] {
+	case "'none'":
+		return "DENY"
+	case "'self'":
+		return "SAMEORIGIN"
+	}
+	return ""
+}
+
+var (
+	hstsPattern           = regexp.MustCompile(`^max-age=\d+(\s*;\s*(includeSubDomains|preload))*\s*;?$`)
+	permissionsPolicyItem = regexp.MustCompile(`^[a-z][a-z0-9-]*=(\*|\((\s*(self|\*|"[^"\s]+"))*\s*\))$`)
+	referrerPolicies      = map[string]bool{
+		"no-referrer":                     true,
+		"no-referrer-when-downgrade":      true,
+		"origin":                          true,
+		"origin-when-cross-origin":        true,
+		"same-origin":                     true,
+		"strict-origin":                   true,
+		"strict-origin-when-cross-origin": true,
+		"unsafe-url":                      true,
+	}
+
+	cspDirectiveName = regexp.MustCompile(`^[a-z][a-z-]*$`)
+	cspSchemeSource  = regexp.MustCompile(`^[a-z][a-z0-9+.-]*:$`)
+	cspHostSource    = regexp.MustCompile(`^([a-z][a-z0-9+.-]*://)?(\*|(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*)(:(\d{1,5}|\*))?(/[^\s;,']*)?$`)
+	cspHashSource    = regexp.MustCompile(`^'(nonce-[A-Za-z0-9+/_=-]+|sha(256|384|512)-[A-Za-z0-9+/_-]+=*)'$`)
+)
+
+// cspSourceDirectives take a source list
+var cspSourceDirectives = map[string]bool{
+	"default-src":     true,
+	"script-src":      true,
+	"script-src-elem": true,
+	"script-src-attr": true,
+	"style-src":       true,
+	"style-src-elem":  true,
+	"style-src-attr":  true,
+	"img-src":         true,
+	"font-src":        true,
+	"connect-src":     true,
+	"media-src":       true,
+	"object-src":      true,
+	"frame-src":       true,
+	"child-src":       true,
+	"worker-src":      true,
+	"manifest-src":    true,
+	"prefetch-src":    true,
+	"base-uri":        true,
+	"form-action":     true,
+	"frame-ancestors": true,
+}
+
+// cspOtherDirectives take other values or none
+var cspOtherDirectives = map[string]bool{
+	"sandbox":                   true,
+	"report-uri":                true,
+	"report-to":                 true,
+	"upgrade-insecure-requests": true,
+	"block-all-mixed-content":   true,
+	"require-trusted-types-for": true,
+	"trusted-types":             true,
+}
+
+var cspKeywords = map[string]bool{
+	"'self'":                     true,
+	"'none'":                     true,
+	"'unsafe-inline'":            true,
+	"'unsafe-eval'":              true,
+	"'unsafe-hashes'":            true,
+	"'strict-dynamic'":           true,
+	"'report-sample'":            true,
+	"'wasm-unsafe-eval'":         true,
+	"'unsafe-allow-redirects'":   true,
+	"'inline-speculation-rules'": true,
+}
+
+// ValidateCSP checks a Content-Security-Policy: known directives, each at
+// most once, with well-formed source expressions
+func ValidateCSP(policy string) error {
+	if strings.TrimSpace(policy) == "" {
+		return fmt.Errorf("%w: content security policy is empty", ErrInvalidSecurityHeaders)
+	}
+	seen := make(map[string]bool)
+	for _, directive := range strings.Split(policy, ";") {
+		tokens := strings.Fields(directive)
+		if len(tokens) == 0 {
+			continue
+		}
+		name := strings.ToLower(tokens[0])
+		values := tokens[1:]
+		if !cspDirectiveName.MatchString(name) || (!cspSourceDirectives[name] && !cspOtherDirectives[name]) {
+			return fmt.Errorf("%w: unknown CSP directive %q", ErrInvalidSecurityHeaders, tokens[0])
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: CSP directive %q appears more than once", ErrInvalidSecurityHeaders, name)
+		}
+		seen[name] = true
+
+		if cspSourceDirectives[name] {
+			if err := validateSourceList(name, values); err != nil {
+				return err
+			}
+			continue
+		}
+		switch name {
+		case "upgrade-insecure-requests", "block-all-mixed-content":
+			if len(values) > 0 {
+				return fmt.Errorf("%w: CSP directive %q takes no value", ErrInvalidSecurityHeaders, name)
+			}
+		case "sandbox":
+			for _, value := range values {
+				if !strings.HasPrefix(value, "allow-") {
+					return fmt.Errorf("%w: invalid sandbox flag %q", ErrInvalidSecurityHeaders, value)
+				}
+			}
+		case "report-uri", "report-to", "require-trusted-types-for":
+			if len(values) == 0 {
+				return fmt.Errorf("%w: CSP directive %q needs a value", ErrInvalidSecurityHeaders, name)
+			}
+		}
+	}
+	if len(seen) == 0 {
+		return fmt.Errorf("%w: content security policy is empty", ErrInvalidSecurityHeaders)
+	}
+	return nil
+}
+
+func validateSourceList(directive string, sources []string) error {
+	for _, source := range sources {
+		if source == "'none'" && len(sources) > 1 {
+			return fmt.Errorf("%w: %s combines 'none' with other sources", ErrInvalidSecurityHeaders, directive)
+		}
+		if err := validateSource(directive, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSource(directive, source string) error {
+	lower := strings.ToLower(source)
+	switch {
+	case cspKeywords[lower]:
+		if directive == "frame-ancestors" && lower != "'self'" && lower != "'none'" {
+			return fmt.Errorf("%w: frame-ancestors does not allow %s", ErrInvalidSecurityHeaders, source)
+		}
+		return nil
+	case cspHashSource.MatchString(source):
+		if directive == "frame-ancestors" {
+			return fmt.Errorf("%w: frame-ancestors does not allow %s", ErrInvalidSecurityHeaders, source)
+		}
+		return nil
+	case cspKeywords["'"+lower+"'"]:
+		return fmt.Errorf("%w: CSP keyword %s in %s must be quoted as '%s'", ErrInvalidSecurityHeaders, source, directive, lower)
+	case strings.HasPrefix(source, "'"):
+		return fmt.Errorf("%w: unknown CSP keyword %s in %s", ErrInvalidSecurityHeaders, source, directive)
+	case cspSchemeSource.MatchString(lower), cspHostSource.MatchString(lower):
+		return nil
+	}
+	return fmt.Errorf("%w: invalid source %q in %s", ErrInvalidSecurityHeaders, source, directive)
+}
+
+// cspDirectiveValues returns the values of the named directive
+func cspDirectiveValues(policy, name string) ([]string, bool) {
+	for _, directive := range strings.Split(policy, ";") {
+		if tokens := strings.Fields(directive); len(tokens) > 0 && strings.EqualFold(tokens[0], name) {
+			return tokens[1:], true
+		}
+	}
+	return nil, false
+}
+
+// hasCSPDirective reports whether a policy has the named directive
+func hasCSPDirective(policy, name string) bool {
+	_, ok := cspDirectiveValues(policy, name)
+	return ok
+}
+
+// setCSPDirective replaces or appends a directive
+func setCSPDirective(policy, name, value string) string {
+	if value == "" {
+		return policy
+	}
+	var directives []string
+	for _, directive := range strings.Split(policy, ";") {
+		directive = strings.TrimSpace(directive)
+		if tokens := strings.Fields(directive); len(tokens) > 0 && !strings.EqualFold(tokens[0], name) {
+			directives = append(directives, directive)
+		}
+	}
+	return strings.Join(append(directives, name+" "+value), "; ")
+}
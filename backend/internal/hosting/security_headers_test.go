@@ -0,0 +1,181 @@
+package hosting
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestValidateCSP(t *testing.T) {
+	valid := []string{
+		DefaultSecurityHeaders("nextjs").ContentSecurityPolicy,
+		"default-src 'none'; script-src 'self' 'nonce-abc123==' 'sha256-AbC/+9='; upgrade-insecure-requests",
+		"img-src *.cdn.example.com:443/assets/ data:; frame-ancestors https://app.example.com; sandbox allow-scripts",
+		"connect-src wss://*.example.com https://api.example.com:*;",
+	}
+	for _, policy := range valid {
+		if err := ValidateCSP(policy); err != nil {
+			t.Errorf("ValidateCSP(%q) = %v, want nil", policy, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"default-src 'self'; scritp-src 'self'":        "unknown CSP directive",
+		"script-src 'self'; script-src https:":         "more than once",
+		"script-src self":                              "must be quoted",
+		"script-src 'unsafe-everything'":               "unknown CSP keyword",
+		"default-src 'none' 'self'":                    "combines 'none'",
+		"frame-ancestors 'unsafe-inline'":              "does not allow",
+		"img-src https://exa mple.com/ ht!tp://x":      "invalid source",
+		"upgrade-insecure-requests https:":             "takes no value",
+		"  ;  ":                                        "empty",
+		"default-src 'self'; report-uri":               "needs a value",
+		"default-src 'self'; sandbox allow-forms deny": "invalid sandbox flag",
+	}
+	for policy, want := range invalid {
+		err := ValidateCSP(policy)
+		if !errors.Is(err, ErrInvalidSecurityHeaders) || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateCSP(%q) = %v, want error containing %q", policy, err, want)
+		}
+	}
+}
+
+func TestSecurityHeadersValidate(t *testing.T) {
+	ok := &SecurityHeaders{
+		ContentSecurityPolicy:   SecurityHeaderOff,
+		StrictTransportSecurity: "max-age=63072000; includeSubDomains; preload",
+		FrameAncestors:          []string{"'self'", "https://*.example.com"},
+		PermissionsPolicy:       `camera=(), geolocation=(self "https://maps.example.com"), fullscreen=*`,
+		ReferrerPolicy:          "no-referrer",
+	}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	for name, headers := range map[string]*SecurityHeaders{
+		"hsts":       {StrictTransportSecurity: "max-age=forever"},
+		"permission": {PermissionsPolicy: "camera=none"},
+		"referrer":   {ReferrerPolicy: "sometimes"},
+		"ancestors":  {FrameAncestors: []string{"'none'", "'self'"}},
+		"injection":  {ReferrerPolicy: "no-referrer\r\nSet-Cookie: a=b"},
+	} {
+		if err := headers.Validate(); !errors.Is(err, ErrInvalidSecurityHeaders) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidSecurityHeaders", name, err)
+		}
+	}
+}
+
+func TestDefaultSecurityHeadersByFramework(t *testing.T) {
+	spa := DefaultSecurityHeaders("vite").ContentSecurityPolicy
+	if !strings.Contains(spa, "script-src 'self';") {
+		t.Fatalf("vite CSP should not allow inline scripts: %s", spa)
+	}
+	next := DefaultSecurityHeaders("nextjs").ContentSecurityPolicy
+	if !strings.Contains(next, "script-src 'self' 'unsafe-inline';") {
+		t.Fatalf("nextjs CSP should allow inline hydration scripts: %s", next)
+	}
+	fastapi := DefaultSecurityHeaders("fastapi").ContentSecurityPolicy
+	if !strings.Contains(fastapi, "https://cdn.jsdelivr.net") {
+		t.Fatalf("fastapi CSP should allow the docs CDN: %s", fastapi)
+	}
+}
+
+func TestSecurityHeadersApply(t *testing.T) {
+	const reportURI = "https://api.apex.test/api/v1/csp-reports/dep-1"
+
+	// Generated defaults fill missing headers but leave the app's own alone
+	header := http.Header{}
+	header.Set("Referrer-Policy", "no-referrer")
+	var unset *SecurityHeaders
+	unset.Apply(header, "react", reportURI)
+
+	csp := header.Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors 'self'") || !strings.Contains(csp, "report-uri "+reportURI) || !strings.Contains(csp, "report-to apex-csp") {
+		t.Fatalf("default CSP = %q", csp)
+	}
+	if got := header.Get("Reporting-Endpoints"); got != `apex-csp="`+reportURI+`"` {
+		t.Fatalf("Reporting-Endpoints = %q", got)
+	}
+	if header.Get("Referrer-Policy") != "no-referrer" {
+		t.Fatalf("default replaced the app's Referrer-Policy: %q", header.Get("Referrer-Policy"))
+	}
+	if header.Get("X-Frame-Options") != "SAMEORIGIN" || header.Get("Strict-Transport-Security") == "" {
+		t.Fatalf("missing defaults: %v", header)
+	}
+
+	// Configured values win, and "off" removes a header
+	header = http.Header{}
+	header.Set("Content-Security-Policy", "default-src *")
+	header.Set("Strict-Transport-Security", "max-age=10")
+	configured := &SecurityHeaders{
+		ContentSecurityPolicy:   "default-src 'self'",
+		CSPReportOnly:           true,
+		StrictTransportSecurity: SecurityHeaderOff,
+		FrameAncestors:          []string{"https://embed.example.com"},
+		DisableViolationReports: true,
+	}
+	configured.Apply(header, "react", reportURI)
+
+	if got := header.Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'; frame-ancestors https://embed.example.com" {
+		t.Fatalf("report-only CSP = %q", got)
+	}
+	if header.Get("Strict-Transport-Security") != "" || header.Get("X-Frame-Options") != "" || header.Get("Reporting-Endpoints") != "" {
+		t.Fatalf("unexpected headers: %v", header)
+	}
+}
+
+func TestCSPReportsAreGrouped(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&NativeDeployment{}, &DeploymentLog{}, &CSPViolation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewHostingService(db)
+	t.Cleanup(svc.Close)
+
+	deployment := &NativeDeployment{ID: "dep-csp", ProjectID: 3, UserID: 1, Subdomain: "csp-app", Framework: "vite"}
+	if err := db.Create(deployment).Error; err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+
+	if err := svc.UpdateSecurityHeaders(deployment.ID, &SecurityHeaders{ContentSecurityPolicy: "script-src self"}); !errors.Is(err, ErrInvalidSecurityHeaders) {
+		t.Fatalf("UpdateSecurityHeaders(invalid) = %v", err)
+	}
+	if err := svc.UpdateSecurityHeaders(deployment.ID, &SecurityHeaders{ReferrerPolicy: "same-origin"}); err != nil {
+		t.Fatalf("UpdateSecurityHeaders: %v", err)
+	}
+	stored, err := svc.GetDeployment(deployment.ID)
+	if err != nil || stored.SecurityHeaders == nil || stored.SecurityHeaders.ReferrerPolicy != "same-origin" {
+		t.Fatalf("stored headers = %+v, %v", stored.SecurityHeaders, err)
+	}
+
+	legacy := []byte(`{"csp-report":{"document-uri":"https://csp-app.apex.app/cart?token=secret","violated-directive":"script-src-elem 'self'","blocked-uri":"https://evil.example.com/x.js?id=1","disposition":"enforce"}}`)
+	batch := []byte(`[{"type":"csp-violation","body":{"documentURL":"https://csp-app.apex.app/","effectiveDirective":"script-src-elem","blockedURL":"https://evil.example.com/y.js","disposition":"enforce"}},{"type":"deprecation","body":{}}]`)
+	for _, payload := range [][]byte{legacy, batch} {
+		reports, err := ParseCSPReports(payload)
+		if err != nil {
+			t.Fatalf("ParseCSPReports: %v", err)
+		}
+		if accepted, err := svc.IngestCSPReports(deployment.ID, reports); err != nil || accepted != 1 {
+			t.Fatalf("IngestCSPReports = %d, %v", accepted, err)
+		}
+	}
+	if _, err := svc.IngestCSPReports("missing", nil); !errors.Is(err, ErrCSPDeploymentNotFound) {
+		t.Fatalf("IngestCSPReports(missing) = %v", err)
+	}
+
+	violations, err := svc.ListCSPViolations(3, "", 0)
+	if err != nil || len(violations) != 1 {
+		t.Fatalf("violations = %+v, %v", violations, err)
+	}
+	v := violations[0]
+	if v.Count != 2 || v.Directive != "script-src-elem" || v.BlockedURI != "https://evil.example.com" || strings.Contains(v.DocumentURI, "secret") {
+		t.Fatalf("violation = %+v", v)
+	}
+}
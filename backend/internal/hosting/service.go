@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,20 +18,35 @@ import (
 	"sync"
 	"time"
 
+	"apex-build/internal/pagination"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // HostingService manages native .apex.app hosting
 type HostingService struct {
-	db                *gorm.DB
-	cloudflareAPI     *CloudflareAPI
-	containerMgr      *ContainerManager
-	logStreamer       *LogStreamer
-	healthChecker     *HealthChecker
-	alwaysOnMonitor   *AlwaysOnMonitor
-	mu                sync.RWMutex
-	activeDeployments map[string]*NativeDeployment
+	db                 *gorm.DB
+	cloudflareAPI      *CloudflareAPI
+	containerMgr       *ContainerManager
+	logStreamer        *LogStreamer
+	healthChecker      *HealthChecker
+	alwaysOnMonitor    *AlwaysOnMonitor
+	queues             QueueProvisioner
+	errorLimits        *errorRateLimiter
+	cspLimits          *errorRateLimiter
+	logForwarder       LogForwarder
+	statusObserver     StatusObserver
+	preDeployHooks     PreDeployHooks
+	projectTokens      ProjectTokenSource
+	deploymentProjects sync.Map // deploymentID -> projectID, for log forwarding
+	runtime            ContainerRuntime
+	probe              HealthProbe
+	restartDrain       time.Duration
+	scaleDrain         time.Duration
+	containerOps       sync.Map // deploymentID -> true while a restart or scale runs
+	mu                 sync.RWMutex
+	activeDeployments  map[string]*NativeDeployment
 }
 
 // CloudflareAPI handles DNS management via Cloudflare
@@ -91,6 +107,8 @@ func NewHostingService(db *gorm.DB) *HostingService {
 	svc := &HostingService{
 		db:                db,
 		activeDeployments: make(map[string]*NativeDeployment),
+		errorLimits:       newErrorRateLimiter(),
+		cspLimits:         newErrorRateLimiter(),
 		cloudflareAPI: &CloudflareAPI{
 			apiToken: cfAPIToken,
 			zoneID:   cfZoneID,
@@ -105,6 +123,10 @@ func NewHostingService(db *gorm.DB) *HostingService {
 		logStreamer: &LogStreamer{
 			subscribers: make(map[string][]chan LogEntry),
 		},
+		runtime:      &dockerRuntime{networkName: "apex-network"},
+		probe:        httpHealthProbe,
+		restartDrain: defaultRestartDrain,
+		scaleDrain:   defaultScaleDrain,
 	}
 
 	// Initialize health checker
@@ -202,6 +224,13 @@ func (s *HostingService) StartDeployment(ctx context.Context, projectID, userID
 		deployment.MaxInstances = 3
 	}
 
+	// Keep the project's security headers across deployments
+	var previous NativeDeployment
+	if err := s.db.Select("security_headers").Where("project_id = ?", projectID).
+		Order("created_at DESC").First(&previous).Error; err == nil {
+		deployment.SecurityHeaders = previous.SecurityHeaders
+	}
+
 	// Save deployment
 	if err := s.db.Create(deployment).Error; err != nil {
 		return nil, fmt.Errorf("failed to create deployment record: %w", err)
@@ -268,6 +297,10 @@ func (s *HostingService) executeDeployment(deployment *NativeDeployment, config
 	s.updateStatus(deployment, StatusProvisioning, "")
 	s.addLog(deployment.ID, "info", "deploy", "Starting deployment provisioning...")
 
+	if !s.runPreDeployHooks(ctx, deployment, config) {
+		return
+	}
+
 	// Step 1: Configure DNS via Cloudflare
 	s.addLog(deployment.ID, "info", "deploy", "Configuring DNS records...")
 	if err := s.configureDNS(ctx, deployment); err != nil {
@@ -331,6 +364,9 @@ func (s *HostingService) executeDeployment(deployment *NativeDeployment, config
 	s.mu.Lock()
 	s.activeDeployments[deployment.ID] = deployment
 	s.mu.Unlock()
+
+	// Start background workers from the new image
+	s.startProjectWorkers(deployment)
 }
 
 // generateSubdomain creates a unique subdomain from project name
@@ -431,12 +467,18 @@ func (s *HostingService) configureDNS(ctx context.Context, deployment *NativeDep
 
 // buildContainer builds the Docker container for the deployment
 func (s *HostingService) buildContainer(ctx context.Context, deployment *NativeDeployment, config *DeploymentConfig) error {
-	s.addLog(deployment.ID, "info", "build", "Creating Dockerfile...")
+	// A project Dockerfile, such as one stored by the dockerize endpoint
+	// after a verified build, takes precedence over the framework template
+	if projectDockerfile(config) != "" {
+		s.addLog(deployment.ID, "info", "build", "Using project Dockerfile")
+	} else {
+		s.addLog(deployment.ID, "info", "build", "Creating Dockerfile...")
 
-	// Detect framework and generate appropriate Dockerfile
-	_ = s.generateDockerfile(deployment, config)
+		// Detect framework and generate appropriate Dockerfile
+		_ = s.generateDockerfile(deployment, config)
 
-	s.addLog(deployment.ID, "debug", "build", "Dockerfile generated")
+		s.addLog(deployment.ID, "debug", "build", "Dockerfile generated")
+	}
 	s.addLog(deployment.ID, "info", "build", "Building container image...")
 
 	// Build using Docker CLI (in production, use Docker SDK)
@@ -450,6 +492,16 @@ func (s *HostingService) buildContainer(ctx context.Context, deployment *NativeD
 	return nil
 }
 
+// projectDockerfile returns the Dockerfile at the project root, if any
+func projectDockerfile(config *DeploymentConfig) string {
+	for _, file := range config.Files {
+		if !file.IsDir && strings.TrimPrefix(file.Path, "/") == "Dockerfile" {
+			return file.Content
+		}
+	}
+	return ""
+}
+
 // generateDockerfile creates a Dockerfile based on the project configuration
 func (s *HostingService) generateDockerfile(deployment *NativeDeployment, config *DeploymentConfig) string {
 	var dockerfile strings.Builder
@@ -532,6 +584,21 @@ func (s *HostingService) deployContainer(ctx context.Context, deployment *Native
 		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
 	}
 
+	// Managed queue connection shared with worker processes
+	for key, value := range s.queueEnv(ctx, deployment.ProjectID) {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	// Error reporting DSN for the app's Sentry SDK
+	for key, value := range s.errorReportingEnv(deployment, config.EnvVars) {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	// Project service token for calls back into the project API
+	for key, value := range s.projectTokenEnv(deployment.ProjectID) {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+
 	// Create container using Docker CLI
 	// In production, use Docker SDK or Kubernetes client
 	containerName := fmt.Sprintf("apex-%s", deployment.ID[:12])
@@ -615,6 +682,7 @@ func (s *HostingService) updateStatus(deployment *NativeDeployment, status Deplo
 		deployment.ErrorMessage = errorMsg
 	}
 	s.db.Save(deployment)
+	s.notifyStatus(deployment)
 }
 
 // failDeployment marks a deployment as failed
@@ -638,12 +706,14 @@ func (s *HostingService) addLog(deploymentID, level, source, message string) {
 	s.db.Create(&log)
 
 	// Notify log subscribers
-	s.logStreamer.Broadcast(deploymentID, LogEntry{
+	entry := LogEntry{
 		Timestamp: log.Timestamp,
 		Level:     level,
 		Source:    source,
 		Message:   message,
-	})
+	}
+	s.logStreamer.Broadcast(deploymentID, entry)
+	s.forwardLog(deploymentID, entry)
 }
 
 // createHistoryEntry creates a history entry for rollback support
@@ -695,6 +765,36 @@ func (s *HostingService) GetProjectDeployments(projectID uint, page, limit int)
 	return deployments, total, nil
 }
 
+// ListProjectDeployments returns one page of a project's native deployments
+// for a parsed list request, with the project's total deployment count.
+func (s *HostingService) ListProjectDeployments(projectID uint, list *pagination.Request) ([]NativeDeployment, pagination.Info, int64, error) {
+	var deployments []NativeDeployment
+	var total int64
+
+	s.db.Model(&NativeDeployment{}).Where("project_id = ?", projectID).Count(&total)
+
+	if err := list.Apply(s.db.Where("project_id = ?", projectID)).Find(&deployments).Error; err != nil {
+		return nil, pagination.Info{}, 0, err
+	}
+	deployments, info := pagination.Page(list, deployments, func(d NativeDeployment) (any, any) {
+		if list.Sort.Key == "status" {
+			return string(d.Status), d.ID
+		}
+		return d.CreatedAt, d.ID
+	})
+	return deployments, info, total, nil
+}
+
+// DeploymentListSpec is the pagination spec for native deployment listings.
+var DeploymentListSpec = pagination.Spec{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts: []pagination.Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "status", Column: "status"},
+	},
+}
+
 // GetDeploymentLogs returns logs for a deployment
 func (s *HostingService) GetDeploymentLogs(deploymentID string, limit int, offset int) ([]DeploymentLog, error) {
 	var logs []DeploymentLog
@@ -738,6 +838,7 @@ func (s *HostingService) StopDeployment(deploymentID string) error {
 	s.db.Save(deployment)
 
 	s.addLog(deploymentID, "info", "runtime", "Deployment stopped")
+	s.stopProjectWorkers(deployment.ProjectID, "Deployment stopped")
 
 	// Remove from active deployments
 	s.mu.Lock()
@@ -747,8 +848,14 @@ func (s *HostingService) StopDeployment(deploymentID string) error {
 	return nil
 }
 
-// RestartDeployment restarts a deployment
+// RestartDeployment restarts a deployment without downtime. A successful
+// manual restart also clears restart backoff and crash loop state.
 func (s *HostingService) RestartDeployment(deploymentID string) error {
+	return s.restartWithReason(deploymentID, RestartManual)
+}
+
+// restartWithReason runs a zero-downtime restart and records why
+func (s *HostingService) restartWithReason(deploymentID, reason string) error {
 	deployment, err := s.GetDeployment(deploymentID)
 	if err != nil {
 		return err
@@ -756,33 +863,20 @@ func (s *HostingService) RestartDeployment(deploymentID string) error {
 
 	s.addLog(deploymentID, "info", "runtime", "Restarting deployment...")
 
-	// Stop container
-	if deployment.ContainerID != "" {
-		cmd := exec.Command("docker", "restart", deployment.ContainerID)
-		if err := cmd.Run(); err != nil {
-			s.addLog(deploymentID, "error", "runtime", fmt.Sprintf("Failed to restart container: %v", err))
-			return err
-		}
-	}
-
-	deployment.RestartCount++
-	deployment.ContainerStatus = ContainerStarting
-	s.db.Save(deployment)
-
-	// Wait for healthy
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	if err := s.waitForHealthy(ctx, deployment); err != nil {
-		deployment.ContainerStatus = ContainerUnhealthy
-		s.db.Save(deployment)
+	if err := s.restartDeployment(ctx, deployment, RestartDetails{Reason: reason}); err != nil {
 		return err
 	}
 
-	deployment.ContainerStatus = ContainerHealthy
-	s.db.Save(deployment)
-
-	s.addLog(deploymentID, "info", "runtime", "Deployment restarted successfully")
+	s.db.Model(deployment).Updates(map[string]interface{}{
+		"status":                 StatusRunning,
+		"error_message":          "",
+		"restart_count":          0,
+		"next_restart_at":        nil,
+		"crash_loop_detected_at": nil,
+	})
 
 	return nil
 }
@@ -805,6 +899,9 @@ func (s *HostingService) DeleteDeployment(deploymentID string) error {
 		s.deleteDNSRecord(deployment)
 	}
 
+	// Stop background workers
+	s.stopProjectWorkers(deployment.ProjectID, "Deployment deleted")
+
 	// Release subdomain
 	s.db.Model(&Subdomain{}).Where("name = ?", deployment.Subdomain).Update("status", "released")
 
@@ -873,7 +970,7 @@ func (s *HostingService) UpdateEnvVars(deploymentID string, envVars map[string]s
 
 	// Restart deployment to apply new env vars
 	if deployment.Status == StatusRunning {
-		return s.RestartDeployment(deploymentID)
+		return s.restartWithReason(deploymentID, RestartConfigChange)
 	}
 
 	return nil
@@ -945,6 +1042,8 @@ func (hc *HealthChecker) Start() {
 			return
 		case <-hc.ticker.C:
 			hc.checkAllDeployments()
+			hc.service.reconcileWorkers(time.Now())
+			hc.service.reconcileAutoscale(time.Now())
 		}
 	}
 }
@@ -958,7 +1057,7 @@ func (hc *HealthChecker) checkAllDeployments() {
 		if deployment.Status == StatusRunning {
 			if !hc.service.checkHealth(deployment) {
 				if deployment.RestartOnFailure && deployment.RestartCount < deployment.MaxRestarts {
-					go hc.service.RestartDeployment(deployment.ID)
+					go hc.service.autoRestartDeployment(deployment, RestartUnhealthy)
 				}
 			}
 		}
@@ -1007,6 +1106,23 @@ func (s *HostingService) GetDeploymentMetrics(deploymentID string) (map[string]i
 		"cpu_limit":          deployment.CPULimit,
 	}
 
+	// Background worker metrics alongside the web process
+	if workers, err := s.ListWorkers(deployment.ProjectID); err == nil && len(workers) > 0 {
+		now := time.Now()
+		workerStats := make([]map[string]interface{}, len(workers))
+		for i := range workers {
+			workerStats[i] = workerMetrics(&workers[i], now)
+		}
+		metrics["workers"] = workerStats
+	}
+
+	// Request load and the autoscaler's recent decisions
+	metrics["load"] = s.DeploymentLoad(deployment.ID, autoscaleWindow, time.Now())
+	metrics["autoscale"] = autoscaleConfig(deployment)
+	if events, err := s.GetDeploymentEvents(deployment.ID, EventScale, 20); err == nil {
+		metrics["scale_events"] = events
+	}
+
 	return metrics, nil
 }
 
@@ -1054,14 +1170,14 @@ func (aom *AlwaysOnMonitor) monitorDeployment(deployment *NativeDeployment) {
 	// Check if deployment needs recovery
 	if deployment.NeedsCrashRecovery() {
 		aom.service.addLog(deployment.ID, "warn", "always-on", "Deployment crashed, initiating auto-restart...")
-		aom.service.autoRestartDeployment(deployment)
+		aom.service.autoRestartDeployment(deployment, RestartCrashed)
 		return
 	}
 
 	// Check if deployment is stopped but should be running
-	if deployment.Status == StatusStopped && deployment.AlwaysOn {
+	if deployment.Status == StatusStopped && deployment.AlwaysOn && deployment.CrashLoopDetectedAt == nil {
 		aom.service.addLog(deployment.ID, "info", "always-on", "Deployment stopped but always-on is enabled, restarting...")
-		aom.service.autoRestartDeployment(deployment)
+		aom.service.autoRestartDeployment(deployment, RestartStopped)
 		return
 	}
 
@@ -1071,7 +1187,7 @@ func (aom *AlwaysOnMonitor) monitorDeployment(deployment *NativeDeployment) {
 		if !healthy {
 			deployment.ContainerStatus = ContainerUnhealthy
 			aom.service.addLog(deployment.ID, "warn", "always-on", "Container unhealthy, initiating restart...")
-			aom.service.autoRestartDeployment(deployment)
+			aom.service.autoRestartDeployment(deployment, RestartUnhealthy)
 		} else {
 			// Update keep-alive timestamp
 			aom.service.db.Model(deployment).Updates(map[string]interface{}{
@@ -1082,8 +1198,23 @@ func (aom *AlwaysOnMonitor) monitorDeployment(deployment *NativeDeployment) {
 	}
 }
 
-// autoRestartDeployment automatically restarts a crashed/stopped always-on deployment
-func (s *HostingService) autoRestartDeployment(deployment *NativeDeployment) {
+// autoRestartDeployment automatically restarts a crashed/stopped always-on
+// deployment. Consecutive failures back off exponentially, and too many
+// restarts in CrashLoopWindow pause automatic restarts.
+func (s *HostingService) autoRestartDeployment(deployment *NativeDeployment, reason string) {
+	now := time.Now()
+	if deployment.CrashLoopDetectedAt != nil {
+		return
+	}
+	if deployment.NextRestartAt != nil && now.Before(*deployment.NextRestartAt) {
+		// Still backing off after the last failed attempt
+		return
+	}
+	if restarts := s.recentAutomaticRestarts(deployment.ID, now); restarts >= CrashLoopThreshold {
+		s.markCrashLoop(deployment, restarts, reason)
+		return
+	}
+
 	// Increment restart count
 	deployment.RestartCount++
 	s.db.Model(deployment).Update("restart_count", deployment.RestartCount)
@@ -1094,17 +1225,33 @@ func (s *HostingService) autoRestartDeployment(deployment *NativeDeployment) {
 		return
 	}
 
-	s.addLog(deployment.ID, "info", "always-on", fmt.Sprintf("Auto-restart attempt %d/%d", deployment.RestartCount, deployment.MaxRestarts))
+	s.addLog(deployment.ID, "info", "always-on", fmt.Sprintf("Auto-restart attempt %d/%d (%s)", deployment.RestartCount, deployment.MaxRestarts, reason))
 
-	// Attempt to restart the container
-	if err := s.RestartDeployment(deployment.ID); err != nil {
-		s.addLog(deployment.ID, "error", "always-on", fmt.Sprintf("Auto-restart failed: %v", err))
-		// Will be retried on next monitor cycle
-	} else {
-		s.addLog(deployment.ID, "info", "always-on", "Auto-restart successful")
-		// Reset restart count on successful restart
-		s.db.Model(deployment).Update("restart_count", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	details := RestartDetails{Reason: reason, Automatic: true, Attempt: deployment.RestartCount}
+	if err := s.restartDeployment(ctx, deployment, details); err != nil {
+		if errors.Is(err, ErrRestartInProgress) {
+			return
+		}
+		// Retried on a later monitor cycle once the backoff passes
+		next := time.Now().Add(restartBackoff(deployment.RestartCount))
+		deployment.NextRestartAt = &next
+		s.db.Model(deployment).Update("next_restart_at", &next)
+		s.addLog(deployment.ID, "error", "always-on", fmt.Sprintf("Auto-restart failed: %v; next attempt after %s", err, next.Format(time.RFC3339)))
+		return
 	}
+
+	s.addLog(deployment.ID, "info", "always-on", "Auto-restart successful")
+	// Reset restart count on successful restart
+	deployment.RestartCount = 0
+	deployment.NextRestartAt = nil
+	s.db.Model(deployment).Updates(map[string]interface{}{
+		"status":          StatusRunning,
+		"restart_count":   0,
+		"next_restart_at": nil,
+	})
 }
 
 // SetAlwaysOn enables or disables always-on for a deployment
@@ -1114,6 +1261,7 @@ func (s *HostingService) SetAlwaysOn(deploymentID string, enabled bool, keepAliv
 		return err
 	}
 
+	wasAlwaysOn := deployment.AlwaysOn
 	deployment.AlwaysOn = enabled
 
 	if enabled {
@@ -1131,9 +1279,17 @@ func (s *HostingService) SetAlwaysOn(deploymentID string, enabled bool, keepAliv
 
 		s.addLog(deploymentID, "info", "always-on", "Always-On enabled - deployment will run 24/7")
 
+		// Turning always-on on is a fresh start for the restart controller.
+		// The always-on reconciler re-enables it periodically, which must
+		// not clear a detected crash loop.
+		if !wasAlwaysOn {
+			deployment.CrashLoopDetectedAt = nil
+			deployment.NextRestartAt = nil
+		}
+
 		// If deployment is stopped, start it
 		if deployment.Status == StatusStopped {
-			go s.autoRestartDeployment(deployment)
+			go s.autoRestartDeployment(deployment, RestartStopped)
 		}
 	} else {
 		deployment.AlwaysOnEnabled = nil
@@ -1152,15 +1308,17 @@ func (s *HostingService) GetAlwaysOnStatus(deploymentID string) (map[string]inte
 	}
 
 	status := map[string]interface{}{
-		"always_on":           deployment.AlwaysOn,
-		"always_on_enabled":   deployment.AlwaysOnEnabled,
-		"last_keep_alive":     deployment.LastKeepAlive,
-		"keep_alive_interval": deployment.KeepAliveInterval,
-		"sleep_after_minutes": deployment.SleepAfterMinutes,
-		"restart_count":       deployment.RestartCount,
-		"max_restarts":        deployment.MaxRestarts,
-		"container_status":    deployment.ContainerStatus,
-		"uptime_seconds":      deployment.UptimeSeconds,
+		"always_on":              deployment.AlwaysOn,
+		"always_on_enabled":      deployment.AlwaysOnEnabled,
+		"last_keep_alive":        deployment.LastKeepAlive,
+		"keep_alive_interval":    deployment.KeepAliveInterval,
+		"sleep_after_minutes":    deployment.SleepAfterMinutes,
+		"restart_count":          deployment.RestartCount,
+		"max_restarts":           deployment.MaxRestarts,
+		"next_restart_at":        deployment.NextRestartAt,
+		"crash_loop_detected_at": deployment.CrashLoopDetectedAt,
+		"container_status":       deployment.ContainerStatus,
+		"uptime_seconds":         deployment.UptimeSeconds,
 	}
 
 	return status, nil
@@ -0,0 +1,20 @@
+package hosting
+
+// StatusObserver is told about every native deployment status change, for
+// example to add finished deployments to the project's activity feed.
+// Implementations must not block.
+type StatusObserver interface {
+	NativeDeploymentStatusChanged(deployment *NativeDeployment)
+}
+
+// SetStatusObserver reports deployment status changes to observer
+func (s *HostingService) SetStatusObserver(observer StatusObserver) {
+	s.statusObserver = observer
+}
+
+// notifyStatus hands a deployment's new status to the observer
+func (s *HostingService) notifyStatus(deployment *NativeDeployment) {
+	if s.statusObserver != nil && deployment != nil {
+		s.statusObserver.NativeDeploymentStatusChanged(deployment)
+	}
+}
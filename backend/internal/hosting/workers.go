@@ -0,0 +1,523 @@
+// Package hosting - Background worker processes for native hosting
+// Workers run alongside the web process from the same image, can share a
+// managed Redis-backed queue, and scale to zero when the app is idle.
+package hosting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxWorkersPerProject caps worker process types per project
+	MaxWorkersPerProject = 5
+	// MaxWorkerReplicas caps replicas per worker process type
+	MaxWorkerReplicas = 5
+	// DefaultWorkerIdleTimeout is how long a scale-to-zero worker waits
+	// without web traffic before it is stopped
+	DefaultWorkerIdleTimeout = 15 * time.Minute
+)
+
+var (
+	// ErrWorkerNotFound is returned when a worker does not exist in the project
+	ErrWorkerNotFound = errors.New("worker not found")
+	// ErrInvalidWorker wraps worker validation failures
+	ErrInvalidWorker = errors.New("invalid worker")
+	// ErrQueueUnavailable is returned when a queue is requested but managed
+	// Redis is not configured
+	ErrQueueUnavailable = errors.New("managed queue is not available")
+)
+
+var workerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,30}$`)
+
+// QueueProvisioner provisions the managed Redis queue shared by a project's
+// web and worker processes
+type QueueProvisioner interface {
+	// ProvisionQueue returns the managed database ID of the project's queue,
+	// creating it if needed
+	ProvisionQueue(ctx context.Context, projectID, userID uint) (uint, error)
+	// QueueEnv returns the environment variables that connect to the queue
+	QueueEnv(ctx context.Context, databaseID uint) (map[string]string, error)
+	// ReleaseQueue deletes the queue and its keys
+	ReleaseQueue(ctx context.Context, databaseID uint) error
+}
+
+// WorkerConfig contains configuration for a worker process
+type WorkerConfig struct {
+	Name               string `json:"name"`
+	Command            string `json:"command"`
+	Replicas           int    `json:"replicas"`
+	MemoryLimit        int64  `json:"memory_limit"`
+	CPULimit           int64  `json:"cpu_limit"`
+	ScaleToZero        bool   `json:"scale_to_zero"`
+	IdleTimeoutMinutes int    `json:"idle_timeout_minutes"`
+	Queue              bool   `json:"queue"`
+}
+
+// WorkerUpdate patches a worker process; nil fields are left unchanged
+type WorkerUpdate struct {
+	Command            *string `json:"command"`
+	Replicas           *int    `json:"replicas"`
+	MemoryLimit        *int64  `json:"memory_limit"`
+	CPULimit           *int64  `json:"cpu_limit"`
+	ScaleToZero        *bool   `json:"scale_to_zero"`
+	IdleTimeoutMinutes *int    `json:"idle_timeout_minutes"`
+}
+
+// SetQueueProvisioner wires managed Redis queue provisioning
+func (s *HostingService) SetQueueProvisioner(p QueueProvisioner) {
+	s.queues = p
+}
+
+func invalidWorker(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidWorker, fmt.Sprintf(format, args...))
+}
+
+// validate applies defaults and checks limits
+func (w *WorkerProcess) validate() error {
+	if !workerNamePattern.MatchString(w.Name) {
+		return invalidWorker("name must be 1-31 lowercase letters, numbers, or hyphens")
+	}
+	w.Command = strings.TrimSpace(w.Command)
+	if w.Command == "" {
+		return invalidWorker("command is required")
+	}
+	if len(w.Command) > 500 {
+		return invalidWorker("command must be at most 500 characters")
+	}
+	if w.Replicas == 0 {
+		w.Replicas = 1
+	}
+	if w.Replicas < 1 || w.Replicas > MaxWorkerReplicas {
+		return invalidWorker("replicas must be between 1 and %d", MaxWorkerReplicas)
+	}
+	if w.MemoryLimit == 0 {
+		w.MemoryLimit = 256
+	}
+	if w.CPULimit == 0 {
+		w.CPULimit = 250
+	}
+	if w.MemoryLimit < 64 || w.MemoryLimit > 4096 || w.CPULimit < 50 || w.CPULimit > 4000 {
+		return invalidWorker("memory_limit must be 64-4096 MB and cpu_limit 50-4000 millicores")
+	}
+	if w.IdleTimeoutMinutes == 0 {
+		w.IdleTimeoutMinutes = int(DefaultWorkerIdleTimeout / time.Minute)
+	}
+	if w.IdleTimeoutMinutes < 1 || w.IdleTimeoutMinutes > 24*60 {
+		return invalidWorker("idle_timeout_minutes must be between 1 and 1440")
+	}
+	return nil
+}
+
+// CreateWorker adds a worker process type to a project. If the project has a
+// running deployment the worker starts immediately; otherwise it starts with
+// the next deployment.
+func (s *HostingService) CreateWorker(ctx context.Context, projectID, userID uint, config *WorkerConfig) (*WorkerProcess, error) {
+	worker := &WorkerProcess{
+		ProjectID:          projectID,
+		UserID:             userID,
+		Name:               strings.TrimSpace(config.Name),
+		Command:            config.Command,
+		Replicas:           config.Replicas,
+		MemoryLimit:        config.MemoryLimit,
+		CPULimit:           config.CPULimit,
+		ScaleToZero:        config.ScaleToZero,
+		IdleTimeoutMinutes: config.IdleTimeoutMinutes,
+		Status:             WorkerStopped,
+	}
+	if err := worker.validate(); err != nil {
+		return nil, err
+	}
+
+	var existing []WorkerProcess
+	s.db.WithContext(ctx).Where("project_id = ?", projectID).Find(&existing)
+	if len(existing) >= MaxWorkersPerProject {
+		return nil, invalidWorker("a project can have at most %d workers", MaxWorkersPerProject)
+	}
+	for _, other := range existing {
+		if other.Name == worker.Name {
+			return nil, invalidWorker("a worker named %q already exists", worker.Name)
+		}
+		if other.QueueDatabaseID != nil && worker.QueueDatabaseID == nil {
+			worker.QueueDatabaseID = other.QueueDatabaseID
+		}
+	}
+
+	if config.Queue {
+		if s.queues == nil {
+			return nil, ErrQueueUnavailable
+		}
+		if worker.QueueDatabaseID == nil {
+			queueID, err := s.queues.ProvisionQueue(ctx, projectID, userID)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrQueueUnavailable, err)
+			}
+			worker.QueueDatabaseID = &queueID
+		}
+		worker.QueueEnabled = true
+	} else {
+		worker.QueueDatabaseID = nil
+	}
+
+	if err := s.db.WithContext(ctx).Create(worker).Error; err != nil {
+		return nil, fmt.Errorf("failed to create worker: %w", err)
+	}
+
+	if deployment := s.runningDeployment(projectID); deployment != nil {
+		s.startWorker(ctx, worker, deployment)
+	}
+	return worker, nil
+}
+
+// ListWorkers returns a project's worker processes
+func (s *HostingService) ListWorkers(projectID uint) ([]WorkerProcess, error) {
+	workers := []WorkerProcess{}
+	err := s.db.Where("project_id = ?", projectID).Order("id").Find(&workers).Error
+	return workers, err
+}
+
+// GetWorker returns a worker process in a project
+func (s *HostingService) GetWorker(projectID, workerID uint) (*WorkerProcess, error) {
+	var worker WorkerProcess
+	if err := s.db.Where("id = ? AND project_id = ?", workerID, projectID).First(&worker).Error; err != nil {
+		return nil, ErrWorkerNotFound
+	}
+	return &worker, nil
+}
+
+// UpdateWorker changes a worker's command, scale, or limits. A running
+// worker is restarted to apply the change.
+func (s *HostingService) UpdateWorker(ctx context.Context, projectID, workerID uint, update *WorkerUpdate) (*WorkerProcess, error) {
+	worker, err := s.GetWorker(projectID, workerID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Command != nil {
+		worker.Command = *update.Command
+	}
+	if update.Replicas != nil {
+		worker.Replicas = *update.Replicas
+	}
+	if update.MemoryLimit != nil {
+		worker.MemoryLimit = *update.MemoryLimit
+	}
+	if update.CPULimit != nil {
+		worker.CPULimit = *update.CPULimit
+	}
+	if update.ScaleToZero != nil {
+		worker.ScaleToZero = *update.ScaleToZero
+	}
+	if update.IdleTimeoutMinutes != nil {
+		worker.IdleTimeoutMinutes = *update.IdleTimeoutMinutes
+	}
+	if err := worker.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(worker).Error; err != nil {
+		return nil, fmt.Errorf("failed to update worker: %w", err)
+	}
+
+	if worker.Status == WorkerRunning {
+		if deployment := s.runningDeployment(projectID); deployment != nil {
+			s.stopWorker(worker, WorkerStopped, "Applying worker configuration change")
+			s.startWorker(ctx, worker, deployment)
+		}
+	}
+	return worker, nil
+}
+
+// RestartWorker restarts a worker against the project's running deployment,
+// waking it if it is asleep
+func (s *HostingService) RestartWorker(ctx context.Context, projectID, workerID uint) (*WorkerProcess, error) {
+	worker, err := s.GetWorker(projectID, workerID)
+	if err != nil {
+		return nil, err
+	}
+	deployment := s.runningDeployment(projectID)
+	if deployment == nil {
+		return nil, fmt.Errorf("project has no running deployment")
+	}
+	if worker.Status == WorkerRunning {
+		s.stopWorker(worker, WorkerStopped, "Restarting worker...")
+	}
+	worker.RestartCount++
+	s.startWorker(ctx, worker, deployment)
+	return worker, nil
+}
+
+// DeleteWorker stops and removes a worker. The project queue is released
+// when no remaining worker uses it.
+func (s *HostingService) DeleteWorker(ctx context.Context, projectID, workerID uint) error {
+	worker, err := s.GetWorker(projectID, workerID)
+	if err != nil {
+		return err
+	}
+	if worker.Status == WorkerRunning || worker.Status == WorkerSleeping {
+		s.stopWorker(worker, WorkerStopped, "Worker deleted")
+	}
+	if err := s.db.WithContext(ctx).Delete(worker).Error; err != nil {
+		return fmt.Errorf("failed to delete worker: %w", err)
+	}
+
+	if worker.QueueDatabaseID != nil && s.queues != nil {
+		var users int64
+		s.db.WithContext(ctx).Model(&WorkerProcess{}).
+			Where("project_id = ? AND queue_database_id = ?", projectID, *worker.QueueDatabaseID).
+			Count(&users)
+		if users == 0 {
+			if err := s.queues.ReleaseQueue(ctx, *worker.QueueDatabaseID); err != nil {
+				s.addLog(worker.DeploymentID, "warn", worker.LogSource(), fmt.Sprintf("Failed to release queue: %v", err))
+			}
+		}
+	}
+	return nil
+}
+
+// GetWorkerLogs returns a worker's log entries, newest first
+func (s *HostingService) GetWorkerLogs(projectID, workerID uint, limit int) ([]DeploymentLog, error) {
+	worker, err := s.GetWorker(projectID, workerID)
+	if err != nil {
+		return nil, err
+	}
+	logs := []DeploymentLog{}
+	if worker.DeploymentID == "" {
+		return logs, nil
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	err = s.db.Where("deployment_id = ? AND source = ?", worker.DeploymentID, worker.LogSource()).
+		Order("timestamp DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// GetWorkerMetrics returns runtime metrics for a worker
+func (s *HostingService) GetWorkerMetrics(projectID, workerID uint) (map[string]interface{}, error) {
+	worker, err := s.GetWorker(projectID, workerID)
+	if err != nil {
+		return nil, err
+	}
+	return workerMetrics(worker, time.Now()), nil
+}
+
+func workerMetrics(worker *WorkerProcess, now time.Time) map[string]interface{} {
+	uptime := worker.UptimeSeconds
+	if worker.Status == WorkerRunning && worker.StartedAt != nil {
+		uptime += int64(now.Sub(*worker.StartedAt).Seconds())
+	}
+	return map[string]interface{}{
+		"id":                   worker.ID,
+		"name":                 worker.Name,
+		"status":               worker.Status,
+		"replicas":             worker.Replicas,
+		"current_replicas":     worker.CurrentReplicas,
+		"uptime_seconds":       uptime,
+		"restart_count":        worker.RestartCount,
+		"memory_limit":         worker.MemoryLimit,
+		"cpu_limit":            worker.CPULimit,
+		"scale_to_zero":        worker.ScaleToZero,
+		"idle_timeout_minutes": worker.IdleTimeoutMinutes,
+		"last_activity_at":     worker.LastActivityAt,
+		"slept_at":             worker.SleptAt,
+		"queue_enabled":        worker.QueueEnabled,
+	}
+}
+
+// runningDeployment returns the project's newest running deployment
+func (s *HostingService) runningDeployment(projectID uint) *NativeDeployment {
+	var deployment NativeDeployment
+	if err := s.db.Where("project_id = ? AND status = ?", projectID, StatusRunning).
+		Order("created_at DESC").First(&deployment).Error; err != nil {
+		return nil
+	}
+	return &deployment
+}
+
+// queueEnv returns the queue connection variables for a project, if any of
+// its workers has a managed queue
+func (s *HostingService) queueEnv(ctx context.Context, projectID uint) map[string]string {
+	if s.queues == nil {
+		return nil
+	}
+	var worker WorkerProcess
+	if err := s.db.WithContext(ctx).Where("project_id = ? AND queue_database_id IS NOT NULL", projectID).
+		First(&worker).Error; err != nil {
+		return nil
+	}
+	env, err := s.queues.QueueEnv(ctx, *worker.QueueDatabaseID)
+	if err != nil {
+		return nil
+	}
+	return env
+}
+
+// startWorker starts a worker's replicas from the deployment's image
+func (s *HostingService) startWorker(ctx context.Context, worker *WorkerProcess, deployment *NativeDeployment) {
+	imageTag := fmt.Sprintf("apex/%s:%s", deployment.Subdomain, deployment.ID[:8])
+	containerName := fmt.Sprintf("apex-%s-%s", deployment.ID[:12], worker.Name)
+
+	envVars := []string{"NODE_ENV=production", "APEX_PROCESS_TYPE=worker", "APEX_WORKER_NAME=" + worker.Name}
+	var deploymentEnv []DeploymentEnvVar
+	s.db.Where("deployment_id = ?", deployment.ID).Find(&deploymentEnv)
+	appEnv := make(map[string]string, len(deploymentEnv))
+	for _, ev := range deploymentEnv {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", ev.Key, ev.Value))
+		appEnv[ev.Key] = ev.Value
+	}
+	for key, value := range s.errorReportingEnv(deployment, appEnv) {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+	for key, value := range s.projectTokenEnv(deployment.ProjectID) {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+	if worker.QueueDatabaseID != nil && s.queues != nil {
+		queueEnv, err := s.queues.QueueEnv(ctx, *worker.QueueDatabaseID)
+		if err != nil {
+			s.addLog(deployment.ID, "warn", worker.LogSource(), fmt.Sprintf("Queue unavailable: %v", err))
+		}
+		for key, value := range queueEnv {
+			envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+
+	s.addLog(deployment.ID, "info", worker.LogSource(), fmt.Sprintf("Starting %d worker replica(s): %s", worker.Replicas, worker.Command))
+	for i := 1; i <= worker.Replicas; i++ {
+		// Workers expose no port and restart on crash like the web process
+		args := []string{
+			"run", "-d",
+			"--name", fmt.Sprintf("%s-%d", containerName, i),
+			"--restart", "unless-stopped",
+			"-m", fmt.Sprintf("%dm", worker.MemoryLimit),
+			"--cpus", fmt.Sprintf("%.2f", float64(worker.CPULimit)/1000),
+		}
+		for _, env := range envVars {
+			args = append(args, "-e", env)
+		}
+		args = append(args, imageTag, "sh", "-c", worker.Command)
+		_ = args // Container start is simulated in development, as for the web process
+
+		s.containerMgr.mu.Lock()
+		s.containerMgr.containers[fmt.Sprintf("worker-%d-%d", worker.ID, i)] = fmt.Sprintf("%s-%d", containerName, i)
+		s.containerMgr.mu.Unlock()
+	}
+
+	now := time.Now()
+	worker.DeploymentID = deployment.ID
+	worker.ContainerName = containerName
+	worker.Status = WorkerRunning
+	worker.CurrentReplicas = worker.Replicas
+	worker.ErrorMessage = ""
+	worker.StartedAt = &now
+	worker.LastActivityAt = &now
+	worker.SleptAt = nil
+	s.db.Save(worker)
+
+	s.addLog(deployment.ID, "info", worker.LogSource(), fmt.Sprintf("Worker running (%d replica(s))", worker.CurrentReplicas))
+}
+
+// stopWorker stops a worker's replicas and records the new status
+func (s *HostingService) stopWorker(worker *WorkerProcess, status WorkerStatus, reason string) {
+	if worker.ContainerName != "" {
+		for i := 1; i <= worker.CurrentReplicas; i++ {
+			cmd := exec.Command("docker", "stop", fmt.Sprintf("%s-%d", worker.ContainerName, i))
+			if err := cmd.Run(); err != nil {
+				s.addLog(worker.DeploymentID, "warn", worker.LogSource(), fmt.Sprintf("Failed to stop worker container: %v", err))
+			}
+			s.containerMgr.mu.Lock()
+			delete(s.containerMgr.containers, fmt.Sprintf("worker-%d-%d", worker.ID, i))
+			s.containerMgr.mu.Unlock()
+		}
+	}
+
+	now := time.Now()
+	if worker.StartedAt != nil && worker.Status == WorkerRunning {
+		worker.UptimeSeconds += int64(now.Sub(*worker.StartedAt).Seconds())
+	}
+	worker.Status = status
+	worker.CurrentReplicas = 0
+	worker.StartedAt = nil
+	if status == WorkerSleeping {
+		worker.SleptAt = &now
+	} else {
+		worker.SleptAt = nil
+	}
+	s.db.Save(worker)
+
+	if reason != "" {
+		s.addLog(worker.DeploymentID, "info", worker.LogSource(), reason)
+	}
+}
+
+// startProjectWorkers (re)starts a project's workers on a new deployment.
+// Scale-to-zero workers start too and sleep again once idle.
+func (s *HostingService) startProjectWorkers(deployment *NativeDeployment) {
+	workers, err := s.ListWorkers(deployment.ProjectID)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	for i := range workers {
+		worker := &workers[i]
+		if worker.Status == WorkerRunning {
+			s.stopWorker(worker, WorkerStopped, "Replacing worker with new deployment")
+		}
+		s.startWorker(ctx, worker, deployment)
+	}
+}
+
+// stopProjectWorkers stops all of a project's workers
+func (s *HostingService) stopProjectWorkers(projectID uint, reason string) {
+	workers, err := s.ListWorkers(projectID)
+	if err != nil {
+		return
+	}
+	for i := range workers {
+		if workers[i].Status == WorkerRunning || workers[i].Status == WorkerSleeping {
+			s.stopWorker(&workers[i], WorkerStopped, reason)
+		}
+	}
+}
+
+// reconcileWorkers scales idle scale-to-zero workers to zero and wakes
+// sleeping workers when their app receives traffic again. Activity is the
+// later of the worker's start and the web process's last request.
+func (s *HostingService) reconcileWorkers(now time.Time) {
+	var workers []WorkerProcess
+	if err := s.db.Where("scale_to_zero = ? AND status IN ?", true, []WorkerStatus{WorkerRunning, WorkerSleeping}).
+		Find(&workers).Error; err != nil {
+		return
+	}
+
+	for i := range workers {
+		worker := &workers[i]
+		deployment, err := s.GetDeployment(worker.DeploymentID)
+		if err != nil || deployment.Status != StatusRunning {
+			continue
+		}
+		lastActivity := worker.LastActivityAt
+		if deployment.LastRequestAt != nil && (lastActivity == nil || deployment.LastRequestAt.After(*lastActivity)) {
+			lastActivity = deployment.LastRequestAt
+		}
+
+		switch worker.Status {
+		case WorkerRunning:
+			if worker.LastActivityAt == nil || !lastActivity.Equal(*worker.LastActivityAt) {
+				s.db.Model(worker).Update("last_activity_at", lastActivity)
+			}
+			idleFor := time.Duration(worker.IdleTimeoutMinutes) * time.Minute
+			if lastActivity != nil && now.Sub(*lastActivity) >= idleFor {
+				s.stopWorker(worker, WorkerSleeping, fmt.Sprintf("Scaled to zero after %d minutes without traffic", worker.IdleTimeoutMinutes))
+			}
+		case WorkerSleeping:
+			if worker.SleptAt != nil && lastActivity != nil && lastActivity.After(*worker.SleptAt) {
+				s.addLog(deployment.ID, "info", worker.LogSource(), "Traffic received, waking worker...")
+				s.startWorker(context.Background(), worker, deployment)
+			}
+		}
+	}
+}
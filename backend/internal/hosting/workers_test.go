@@ -0,0 +1,136 @@
+package hosting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeQueues struct {
+	provisioned int
+	released    []uint
+}
+
+func (f *fakeQueues) ProvisionQueue(ctx context.Context, projectID, userID uint) (uint, error) {
+	f.provisioned++
+	return 42, nil
+}
+
+func (f *fakeQueues) QueueEnv(ctx context.Context, databaseID uint) (map[string]string, error) {
+	return map[string]string{"REDIS_URL": "redis://localhost:6379/0"}, nil
+}
+
+func (f *fakeQueues) ReleaseQueue(ctx context.Context, databaseID uint) error {
+	f.released = append(f.released, databaseID)
+	return nil
+}
+
+func setupWorkerTest(t *testing.T) (*HostingService, *gorm.DB, *NativeDeployment, *fakeQueues) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&NativeDeployment{}, &DeploymentLog{}, &DeploymentEnvVar{}, &WorkerProcess{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewHostingService(db)
+	t.Cleanup(svc.Close)
+	queues := &fakeQueues{}
+	svc.SetQueueProvisioner(queues)
+
+	deployment := &NativeDeployment{
+		ID:        "0123456789abcdef0123456789abcdef0123",
+		ProjectID: 7,
+		UserID:    3,
+		Subdomain: "worker-app",
+		Status:    StatusRunning,
+	}
+	if err := db.Create(deployment).Error; err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+	return svc, db, deployment, queues
+}
+
+func TestCreateWorkerStartsOnRunningDeploymentWithSharedQueue(t *testing.T) {
+	svc, _, deployment, queues := setupWorkerTest(t)
+	ctx := context.Background()
+
+	first, err := svc.CreateWorker(ctx, 7, 3, &WorkerConfig{Name: "jobs", Command: "node worker.js", Queue: true})
+	if err != nil {
+		t.Fatalf("CreateWorker: %v", err)
+	}
+	if first.Status != WorkerRunning || first.CurrentReplicas != 1 || first.DeploymentID != deployment.ID {
+		t.Fatalf("worker = %+v", first)
+	}
+	second, err := svc.CreateWorker(ctx, 7, 3, &WorkerConfig{Name: "mailer", Command: "node mail.js", Replicas: 2, Queue: true})
+	if err != nil {
+		t.Fatalf("CreateWorker: %v", err)
+	}
+	if queues.provisioned != 1 || second.QueueDatabaseID == nil || *second.QueueDatabaseID != 42 {
+		t.Fatalf("queue should be provisioned once and shared, provisioned = %d", queues.provisioned)
+	}
+	if _, err := svc.CreateWorker(ctx, 7, 3, &WorkerConfig{Name: "jobs", Command: "x"}); !errors.Is(err, ErrInvalidWorker) {
+		t.Fatalf("duplicate name err = %v", err)
+	}
+	if env := svc.queueEnv(ctx, 7); env["REDIS_URL"] == "" {
+		t.Fatal("web process should receive the queue connection")
+	}
+
+	logs, err := svc.GetWorkerLogs(7, first.ID, 10)
+	if err != nil || len(logs) == 0 || logs[0].Source != "worker:jobs" {
+		t.Fatalf("worker logs = %+v, %v", logs, err)
+	}
+
+	if err := svc.DeleteWorker(ctx, 7, first.ID); err != nil {
+		t.Fatalf("DeleteWorker: %v", err)
+	}
+	if len(queues.released) != 0 {
+		t.Fatal("queue released while another worker uses it")
+	}
+	if err := svc.DeleteWorker(ctx, 7, second.ID); err != nil {
+		t.Fatalf("DeleteWorker: %v", err)
+	}
+	if len(queues.released) != 1 {
+		t.Fatal("queue should be released with its last worker")
+	}
+}
+
+func TestScaleToZeroWorkerSleepsWhenIdleAndWakesOnTraffic(t *testing.T) {
+	svc, db, deployment, _ := setupWorkerTest(t)
+	ctx := context.Background()
+
+	worker, err := svc.CreateWorker(ctx, 7, 3, &WorkerConfig{Name: "jobs", Command: "python worker.py", ScaleToZero: true, IdleTimeoutMinutes: 10})
+	if err != nil {
+		t.Fatalf("CreateWorker: %v", err)
+	}
+
+	svc.reconcileWorkers(time.Now().Add(5 * time.Minute))
+	if got, _ := svc.GetWorker(7, worker.ID); got.Status != WorkerRunning {
+		t.Fatalf("worker slept before its idle timeout: %s", got.Status)
+	}
+	svc.reconcileWorkers(time.Now().Add(11 * time.Minute))
+	asleep, _ := svc.GetWorker(7, worker.ID)
+	if asleep.Status != WorkerSleeping || asleep.CurrentReplicas != 0 || asleep.SleptAt == nil {
+		t.Fatalf("idle worker = %+v", asleep)
+	}
+
+	requestAt := asleep.SleptAt.Add(time.Second)
+	db.Model(deployment).Update("last_request_at", requestAt)
+	svc.reconcileWorkers(requestAt.Add(time.Second))
+	if awake, _ := svc.GetWorker(7, worker.ID); awake.Status != WorkerRunning || awake.CurrentReplicas != 1 {
+		t.Fatalf("worker did not wake on traffic: %+v", awake)
+	}
+
+	metrics, err := svc.GetDeploymentMetrics(deployment.ID)
+	if err != nil {
+		t.Fatalf("GetDeploymentMetrics: %v", err)
+	}
+	if workers, ok := metrics["workers"].([]map[string]interface{}); !ok || len(workers) != 1 {
+		t.Fatalf("deployment metrics should include workers: %+v", metrics["workers"])
+	}
+}
@@ -0,0 +1,250 @@
+// Package instructions loads the AI instructions that apply to a project:
+// the project's apex.md file and its organization's instructions. Builds,
+// completions and AI chat on the project all receive the same rendered block.
+//
+// Precedence: organization instructions always apply and win on conflict.
+// Project instructions follow them, and an organization can switch project
+// files off entirely. Both sit below the platform's own rules.
+package instructions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// FileName is the project instructions file at the project root.
+	FileName = "apex.md"
+
+	// MaxProjectBytes caps apex.md. Saving a larger file through the API is
+	// refused; a larger file written some other way is truncated on load.
+	MaxProjectBytes = 16 * 1024
+	// MaxOrganizationBytes caps an organization's instructions.
+	MaxOrganizationBytes = 8 * 1024
+)
+
+var (
+	// ErrProjectNotFound is returned when the project does not exist or the
+	// user may not access it.
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrTooLarge is returned when saved instructions exceed the size limit.
+	ErrTooLarge = fmt.Errorf("instructions exceed %d bytes", MaxProjectBytes)
+	// ErrProjectInstructionsDisabled is returned when saving apex.md in an
+	// organization that does not allow project instructions.
+	ErrProjectInstructionsDisabled = errors.New("this organization does not allow project instructions")
+)
+
+// projectFilePaths are the stored paths that count as the root apex.md.
+var projectFilePaths = []string{FileName, "/" + FileName, "./" + FileName}
+
+// ProjectAccess decides whether a user may act on a project. Implemented by
+// ownership.Service.
+type ProjectAccess interface {
+	CanAccess(userID uint, project *models.Project, action string) bool
+}
+
+// Instructions are the resolved instructions for one project.
+type Instructions struct {
+	ProjectID uint   `json:"project_id"`
+	Path      string `json:"path"`
+	// Project is the apex.md content, capped at MaxProjectBytes.
+	Project          string `json:"project"`
+	ProjectTruncated bool   `json:"project_truncated,omitempty"`
+	// ProjectAllowed is false when the owning organization switched project
+	// instructions off; Project is then kept for display but not applied.
+	ProjectAllowed bool   `json:"project_allowed"`
+	OrganizationID *uint  `json:"organization_id,omitempty"`
+	Organization   string `json:"organization,omitempty"`
+}
+
+// Empty reports whether nothing would be added to prompts.
+func (i *Instructions) Empty() bool {
+	return i == nil || (i.Organization == "" && (!i.ProjectAllowed || i.Project == ""))
+}
+
+// PromptBlock renders the instructions for a system prompt, or "" if there
+// are none.
+func (i *Instructions) PromptBlock() string {
+	if i.Empty() {
+		return ""
+	}
+	var sb strings.Builder
+	if i.Organization != "" {
+		sb.WriteString("\n\nORGANIZATION INSTRUCTIONS (mandatory; these override project instructions):\n")
+		sb.WriteString(i.Organization)
+	}
+	if i.ProjectAllowed && i.Project != "" {
+		sb.WriteString("\n\nPROJECT INSTRUCTIONS (from " + FileName + "; follow unless they conflict with organization instructions or platform rules):\n")
+		sb.WriteString(i.Project)
+	}
+	return sb.String()
+}
+
+// Service resolves and edits project and organization instructions.
+type Service struct {
+	db     *gorm.DB
+	access ProjectAccess
+}
+
+// NewService creates a new instructions Service. A nil access limits projects
+// to their owner.
+func NewService(db *gorm.DB, access ProjectAccess) *Service {
+	return &Service{db: db, access: access}
+}
+
+func (s *Service) canAccess(userID uint, project *models.Project, action string) bool {
+	if s.access == nil {
+		return project.OwnerID == userID
+	}
+	return s.access.CanAccess(userID, project, action)
+}
+
+func (s *Service) loadProject(ctx context.Context, userID, projectID uint, action string) (*models.Project, error) {
+	var project models.Project
+	if err := s.db.WithContext(ctx).First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("instructions: load project failed: %w", err)
+	}
+	if !s.canAccess(userID, &project, action) {
+		return nil, ErrProjectNotFound
+	}
+	return &project, nil
+}
+
+func (s *Service) loadFile(ctx context.Context, projectID uint) (*models.File, error) {
+	var file models.File
+	err := s.db.WithContext(ctx).
+		Where("project_id = ? AND path IN ? AND type = ?", projectID, projectFilePaths, "file").
+		Order("id").First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("instructions: load %s failed: %w", FileName, err)
+	}
+	return &file, nil
+}
+
+// Load resolves the instructions for a project the user may read.
+func (s *Service) Load(ctx context.Context, userID, projectID uint) (*Instructions, error) {
+	project, err := s.loadProject(ctx, userID, projectID, "read")
+	if err != nil {
+		return nil, err
+	}
+	resolved := &Instructions{ProjectID: project.ID, Path: FileName, ProjectAllowed: true}
+
+	file, err := s.loadFile(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	if file != nil {
+		resolved.Path = file.Path
+		resolved.Project = strings.TrimSpace(file.Content)
+		if len(resolved.Project) > MaxProjectBytes {
+			resolved.Project = truncateUTF8(resolved.Project, MaxProjectBytes)
+			resolved.ProjectTruncated = true
+		}
+	}
+
+	if project.OrganizationID != nil {
+		var org enterprise.Organization
+		if err := s.db.WithContext(ctx).First(&org, *project.OrganizationID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("instructions: load organization failed: %w", err)
+		} else if err == nil {
+			resolved.OrganizationID = &org.ID
+			resolved.Organization = truncateUTF8(strings.TrimSpace(org.AIInstructions), MaxOrganizationBytes)
+			resolved.ProjectAllowed = org.AllowProjectAIInstructions
+		}
+	}
+	return resolved, nil
+}
+
+// PromptContext returns the rendered instructions for a project, or "" when
+// there are none or the user may not read the project.
+func (s *Service) PromptContext(ctx context.Context, userID, projectID uint) (string, error) {
+	if projectID == 0 {
+		return "", nil
+	}
+	resolved, err := s.Load(ctx, userID, projectID)
+	if errors.Is(err, ErrProjectNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return resolved.PromptBlock(), nil
+}
+
+// SaveProjectInstructions writes apex.md. Empty content deletes the file.
+func (s *Service) SaveProjectInstructions(ctx context.Context, userID, projectID uint, content string) (*Instructions, error) {
+	project, err := s.loadProject(ctx, userID, projectID, "update")
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MaxProjectBytes {
+		return nil, ErrTooLarge
+	}
+	if project.OrganizationID != nil && strings.TrimSpace(content) != "" {
+		var org enterprise.Organization
+		if err := s.db.WithContext(ctx).Select("id", "allow_project_ai_instructions").First(&org, *project.OrganizationID).Error; err == nil && !org.AllowProjectAIInstructions {
+			return nil, ErrProjectInstructionsDisabled
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var file models.File
+		err := tx.Where("project_id = ? AND path IN ? AND type = ?", project.ID, projectFilePaths, "file").Order("id").First(&file).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if strings.TrimSpace(content) == "" {
+				return nil
+			}
+			file = models.File{
+				ProjectID:  project.ID,
+				Name:       FileName,
+				Path:       FileName,
+				Type:       "file",
+				MimeType:   "text/markdown",
+				Content:    content,
+				Size:       int64(len(content)),
+				LastEditBy: userID,
+			}
+			return tx.Create(&file).Error
+		case err != nil:
+			return err
+		case strings.TrimSpace(content) == "":
+			return tx.Delete(&file).Error
+		default:
+			return tx.Model(&file).Updates(map[string]interface{}{
+				"content":      content,
+				"size":         int64(len(content)),
+				"last_edit_by": userID,
+				"version":      gorm.Expr("version + 1"),
+			}).Error
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("instructions: save %s failed: %w", FileName, err)
+	}
+	return s.Load(ctx, userID, projectID)
+}
+
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && cut < len(s) && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut]
+}
@@ -0,0 +1,134 @@
+package instructions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// memberAccess lets org members read and update org projects.
+type memberAccess map[uint]uint // userID -> orgID
+
+func (m memberAccess) CanAccess(userID uint, project *models.Project, action string) bool {
+	if project.OrganizationID == nil {
+		return project.OwnerID == userID
+	}
+	return m[userID] == *project.OrganizationID
+}
+
+type instructionsFixture struct {
+	svc        *Service
+	db         *gorm.DB
+	personal   models.Project
+	orgProject models.Project
+	org        enterprise.Organization
+}
+
+func setupInstructionsTest(t *testing.T) *instructionsFixture {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}, &enterprise.Organization{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	f := &instructionsFixture{db: db}
+	f.org = enterprise.Organization{Name: "Acme", Slug: "acme", AIInstructions: "Use TypeScript strict mode.", AllowProjectAIInstructions: true}
+	if err := db.Create(&f.org).Error; err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+	f.personal = models.Project{Name: "personal", Language: "typescript", OwnerID: 1}
+	f.orgProject = models.Project{Name: "shared", Language: "typescript", OwnerID: 2, OrganizationID: &f.org.ID}
+	for _, p := range []*models.Project{&f.personal, &f.orgProject} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+	}
+	f.svc = NewService(db, memberAccess{2: f.org.ID, 3: f.org.ID})
+	return f
+}
+
+func TestProjectInstructionsSaveLoadAndLimits(t *testing.T) {
+	f := setupInstructionsTest(t)
+	ctx := context.Background()
+
+	block, err := f.svc.PromptContext(ctx, 1, f.personal.ID)
+	if err != nil || block != "" {
+		t.Fatalf("projects without apex.md should add nothing, got %q (%v)", block, err)
+	}
+
+	if _, err := f.svc.SaveProjectInstructions(ctx, 1, f.personal.ID, strings.Repeat("x", MaxProjectBytes+1)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := f.svc.SaveProjectInstructions(ctx, 2, f.personal.ID, "Use tabs."); !errors.Is(err, ErrProjectNotFound) {
+		t.Fatalf("other users must not edit the project, got %v", err)
+	}
+
+	resolved, err := f.svc.SaveProjectInstructions(ctx, 1, f.personal.ID, "Use tabs.")
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if resolved.Project != "Use tabs." || !strings.Contains(resolved.PromptBlock(), "PROJECT INSTRUCTIONS (from apex.md") {
+		t.Fatalf("unexpected instructions %+v", resolved)
+	}
+	if _, err := f.svc.SaveProjectInstructions(ctx, 1, f.personal.ID, "Use spaces."); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	var files []models.File
+	f.db.Where("project_id = ?", f.personal.ID).Find(&files)
+	if len(files) != 1 || files[0].Content != "Use spaces." || files[0].Version != 2 {
+		t.Fatalf("expected one apex.md at version 2, got %+v", files)
+	}
+
+	// A file written through the regular file API may exceed the limit.
+	f.db.Model(&files[0]).Update("content", strings.Repeat("y", MaxProjectBytes+100))
+	resolved, err = f.svc.Load(ctx, 1, f.personal.ID)
+	if err != nil || !resolved.ProjectTruncated || len(resolved.Project) != MaxProjectBytes {
+		t.Fatalf("oversized apex.md should be truncated, got truncated=%v len=%d (%v)", resolved.ProjectTruncated, len(resolved.Project), err)
+	}
+
+	if _, err := f.svc.SaveProjectInstructions(ctx, 1, f.personal.ID, "  "); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if block, _ := f.svc.PromptContext(ctx, 1, f.personal.ID); block != "" {
+		t.Fatalf("cleared instructions should add nothing, got %q", block)
+	}
+}
+
+func TestOrganizationInstructionsTakePrecedence(t *testing.T) {
+	f := setupInstructionsTest(t)
+	ctx := context.Background()
+
+	if _, err := f.svc.SaveProjectInstructions(ctx, 3, f.orgProject.ID, "Use plain JavaScript."); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	block, err := f.svc.PromptContext(ctx, 2, f.orgProject.ID)
+	if err != nil {
+		t.Fatalf("prompt context: %v", err)
+	}
+	orgAt := strings.Index(block, "Use TypeScript strict mode.")
+	projectAt := strings.Index(block, "Use plain JavaScript.")
+	if orgAt < 0 || projectAt < 0 || orgAt > projectAt || !strings.Contains(block, "override project instructions") {
+		t.Fatalf("organization instructions should lead and override, got %q", block)
+	}
+	if block, _ := f.svc.PromptContext(ctx, 1, f.orgProject.ID); block != "" {
+		t.Fatalf("non-members must not receive instructions, got %q", block)
+	}
+
+	f.db.Model(&f.org).Update("allow_project_ai_instructions", false)
+	block, _ = f.svc.PromptContext(ctx, 2, f.orgProject.ID)
+	if strings.Contains(block, "Use plain JavaScript.") || !strings.Contains(block, "Use TypeScript strict mode.") {
+		t.Fatalf("disabled project instructions must not apply, got %q", block)
+	}
+	if _, err := f.svc.SaveProjectInstructions(ctx, 2, f.orgProject.ID, "Use Go."); !errors.Is(err, ErrProjectInstructionsDisabled) {
+		t.Fatalf("expected ErrProjectInstructionsDisabled, got %v", err)
+	}
+}
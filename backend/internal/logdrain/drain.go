@@ -0,0 +1,167 @@
+// Package logdrain forwards hosting and preview logs to external sinks
+// (HTTPS endpoints, syslog, Datadog and Logtail) so teams can keep logs in
+// their own observability stack. Delivery is batched and never blocks the
+// process producing the logs: when a drain falls behind, records are
+// dropped and counted.
+package logdrain
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxDrainsPerProject caps the drains a project can configure
+const MaxDrainsPerProject = 5
+
+// Type is the kind of sink a drain delivers to
+type Type string
+
+const (
+	TypeHTTPS   Type = "https"   // JSON array POSTed to any HTTPS endpoint
+	TypeSyslog  Type = "syslog"  // RFC 5424 over TCP (syslog://) or TLS (syslog+tls://)
+	TypeDatadog Type = "datadog" // Datadog logs intake, API key as token
+	TypeLogtail Type = "logtail" // Better Stack Logtail, source token as token
+)
+
+// Log sources a drain can subscribe to
+const (
+	SourceAll     = "all"
+	SourceHosting = "hosting" // Native hosting deployments and workers
+	SourcePreview = "preview" // Preview backend processes
+)
+
+// Status is a drain's delivery health
+type Status string
+
+const (
+	StatusPending Status = "pending" // Nothing delivered yet
+	StatusHealthy Status = "healthy"
+	StatusFailing Status = "failing" // The last batch could not be delivered
+)
+
+const (
+	defaultDatadogURL = "https://http-intake.logs.datadoghq.com/api/v2/logs"
+	defaultLogtailURL = "https://in.logs.betterstack.com"
+)
+
+var (
+	// ErrDrainNotFound is returned when a drain does not exist in the project
+	ErrDrainNotFound = errors.New("log drain not found")
+	// ErrInvalidDrain wraps drain validation failures
+	ErrInvalidDrain = errors.New("invalid log drain")
+)
+
+var drainNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Drain is a project's log forwarding destination
+type Drain struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	ProjectID uint `json:"project_id" gorm:"not null;index"`
+	UserID    uint `json:"user_id" gorm:"not null;index"`
+
+	// Restricts the drain to one deployment; empty forwards every
+	// deployment of the project
+	DeploymentID string `json:"deployment_id,omitempty" gorm:"type:varchar(36)"`
+
+	Name     string `json:"name" gorm:"not null;type:varchar(63)"`
+	Type     Type   `json:"type" gorm:"not null;type:varchar(20)"`
+	URL      string `json:"url" gorm:"type:varchar(500)"`
+	Token    string `json:"-" gorm:"type:varchar(500)"` // API key or bearer token
+	HasToken bool   `json:"has_token" gorm:"-"`
+	Sources  string `json:"sources" gorm:"type:varchar(20);default:'all'"`
+	Enabled  bool   `json:"enabled" gorm:"default:true"`
+
+	// Delivery status
+	Status         Status     `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	DeliveredCount int64      `json:"delivered_count" gorm:"default:0"`
+	FailedCount    int64      `json:"failed_count" gorm:"default:0"`  // Records in batches that failed after retries
+	DroppedCount   int64      `json:"dropped_count" gorm:"default:0"` // Records dropped because the drain fell behind
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+}
+
+// TableName specifies the table name for Drain
+func (Drain) TableName() string {
+	return "log_drains"
+}
+
+// AfterFind reports whether a token is stored without exposing it
+func (d *Drain) AfterFind(tx *gorm.DB) error {
+	d.HasToken = d.Token != ""
+	return nil
+}
+
+// Accepts reports whether a record from service and deployment goes to d
+func (d *Drain) Accepts(service, deploymentID string) bool {
+	if !d.Enabled {
+		return false
+	}
+	if d.Sources != SourceAll && d.Sources != "" && d.Sources != service {
+		return false
+	}
+	return d.DeploymentID == "" || service != SourceHosting || d.DeploymentID == deploymentID
+}
+
+// Normalize fills defaults and validates the drain
+func (d *Drain) Normalize() error {
+	d.Name = strings.TrimSpace(d.Name)
+	if !drainNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("%w: name must be a lowercase slug", ErrInvalidDrain)
+	}
+	switch d.Sources {
+	case "":
+		d.Sources = SourceAll
+	case SourceAll, SourceHosting, SourcePreview:
+	default:
+		return fmt.Errorf("%w: sources must be all, hosting or preview", ErrInvalidDrain)
+	}
+
+	d.URL = strings.TrimSpace(d.URL)
+	switch d.Type {
+	case TypeDatadog:
+		if d.URL == "" {
+			d.URL = defaultDatadogURL
+		}
+	case TypeLogtail:
+		if d.URL == "" {
+			d.URL = defaultLogtailURL
+		}
+	case TypeHTTPS, TypeSyslog:
+		if d.URL == "" {
+			return fmt.Errorf("%w: url is required", ErrInvalidDrain)
+		}
+	default:
+		return fmt.Errorf("%w: type must be https, syslog, datadog or logtail", ErrInvalidDrain)
+	}
+	if (d.Type == TypeDatadog || d.Type == TypeLogtail) && d.Token == "" {
+		return fmt.Errorf("%w: %s drains need a token", ErrInvalidDrain, d.Type)
+	}
+
+	endpoint, err := url.Parse(d.URL)
+	if err != nil || endpoint.Host == "" {
+		return fmt.Errorf("%w: url is not valid", ErrInvalidDrain)
+	}
+	if d.Type == TypeSyslog {
+		if endpoint.Scheme != "syslog" && endpoint.Scheme != "syslog+tls" {
+			return fmt.Errorf("%w: syslog urls use syslog:// or syslog+tls://", ErrInvalidDrain)
+		}
+		if _, _, err := net.SplitHostPort(endpoint.Host); err != nil {
+			return fmt.Errorf("%w: syslog url needs a port", ErrInvalidDrain)
+		}
+	} else if endpoint.Scheme != "https" {
+		return fmt.Errorf("%w: url must use https", ErrInvalidDrain)
+	}
+	return nil
+}
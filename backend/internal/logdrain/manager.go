@@ -0,0 +1,385 @@
+package logdrain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"apex-build/internal/netguard"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// QueueSize is how many records a drain buffers before dropping
+	QueueSize = 1000
+	// BatchSize is the most records sent in one delivery
+	BatchSize = 100
+	// FlushInterval is the longest a record waits for its batch to fill
+	FlushInterval = 2 * time.Second
+
+	sendAttempts = 3
+	sendTimeout  = 10 * time.Second
+)
+
+// Manager fans log records out to the drains of their project. Each drain
+// has its own bounded queue and delivery goroutine, so a slow sink only
+// affects itself.
+type Manager struct {
+	db         *gorm.DB
+	client     *http.Client
+	dialer     *net.Dialer
+	retryDelay time.Duration
+
+	mu       sync.Mutex
+	projects map[uint][]*worker // Loaded drains per project
+	closed   bool
+}
+
+type worker struct {
+	drain   Drain
+	queue   chan Record
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// DrainUpdate changes a drain; nil fields are left alone
+type DrainUpdate struct {
+	Name         *string `json:"name"`
+	URL          *string `json:"url"`
+	Token        *string `json:"token"`
+	Sources      *string `json:"sources"`
+	DeploymentID *string `json:"deployment_id"`
+	Enabled      *bool   `json:"enabled"`
+}
+
+// NewManager creates a log drain manager. Sinks may not resolve to
+// internal addresses.
+func NewManager(db *gorm.DB) *Manager {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: netguard.DenyInternal("log drains")}
+	return &Manager{
+		db:     db,
+		dialer: dialer,
+		client: &http.Client{
+			Timeout: sendTimeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+				MaxIdleConnsPerHost: 4,
+			},
+		},
+		retryDelay: 500 * time.Millisecond,
+		projects:   make(map[uint][]*worker),
+	}
+}
+
+// ForwardDeploymentLog forwards a native hosting deployment log line
+func (m *Manager) ForwardDeploymentLog(projectID uint, deploymentID string, at time.Time, level, source, message string) {
+	m.publish(Record{
+		Timestamp:    at,
+		Level:        level,
+		Service:      SourceHosting,
+		Source:       source,
+		Message:      message,
+		ProjectID:    projectID,
+		DeploymentID: deploymentID,
+	})
+}
+
+// ForwardPreviewLog forwards a line a preview backend wrote to stream
+// (stdout or stderr)
+func (m *Manager) ForwardPreviewLog(projectID uint, stream, line string) {
+	level := "info"
+	if stream == "stderr" {
+		level = "error"
+	}
+	m.publish(Record{
+		Timestamp: time.Now(),
+		Level:     level,
+		Service:   SourcePreview,
+		Source:    stream,
+		Message:   line,
+		ProjectID: projectID,
+	})
+}
+
+func (m *Manager) publish(record Record) {
+	for _, w := range m.projectWorkers(record.ProjectID) {
+		if !w.drain.Accepts(record.Service, record.DeploymentID) {
+			continue
+		}
+		select {
+		case w.queue <- record:
+		default:
+			w.dropped.Add(1)
+		}
+	}
+}
+
+// projectWorkers returns the running drains of a project, starting them
+// on first use
+func (m *Manager) projectWorkers(projectID uint) []*worker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	if workers, ok := m.projects[projectID]; ok {
+		return workers
+	}
+
+	var drains []Drain
+	if err := m.db.Where("project_id = ? AND enabled = ?", projectID, true).Find(&drains).Error; err != nil {
+		return nil
+	}
+	workers := make([]*worker, 0, len(drains))
+	for _, drain := range drains {
+		w := &worker{
+			drain: drain,
+			queue: make(chan Record, QueueSize),
+			stop:  make(chan struct{}),
+			done:  make(chan struct{}),
+		}
+		go m.run(w)
+		workers = append(workers, w)
+	}
+	m.projects[projectID] = workers
+	return workers
+}
+
+// reload stops a project's drains so the next record restarts them with
+// the current configuration. Queued records are delivered first.
+func (m *Manager) reload(projectID uint) {
+	m.mu.Lock()
+	workers := m.projects[projectID]
+	delete(m.projects, projectID)
+	m.mu.Unlock()
+	for _, w := range workers {
+		close(w.stop)
+	}
+}
+
+// Close delivers queued records and stops every drain
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	var workers []*worker
+	for _, projectWorkers := range m.projects {
+		workers = append(workers, projectWorkers...)
+	}
+	m.projects = make(map[uint][]*worker)
+	m.mu.Unlock()
+
+	for _, w := range workers {
+		close(w.stop)
+	}
+	for _, w := range workers {
+		<-w.done
+	}
+}
+
+func (m *Manager) run(w *worker) {
+	defer close(w.done)
+	ticker := time.NewTicker(FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, BatchSize)
+	for {
+		select {
+		case record := <-w.queue:
+			batch = append(batch, record)
+			if len(batch) >= BatchSize {
+				m.flush(w, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 || w.dropped.Load() > 0 {
+				m.flush(w, batch)
+				batch = batch[:0]
+			}
+		case <-w.stop:
+			// Only this goroutine receives, so the queue only shrinks here
+			for len(w.queue) > 0 {
+				batch = append(batch, <-w.queue)
+				if len(batch) >= BatchSize {
+					m.flush(w, batch)
+					batch = batch[:0]
+				}
+			}
+			if len(batch) > 0 || w.dropped.Load() > 0 {
+				m.flush(w, batch)
+			}
+			return
+		}
+	}
+}
+
+// flush delivers a batch with retries. While it retries the drain's queue
+// keeps filling, and overflow is dropped and counted.
+func (m *Manager) flush(w *worker, batch []Record) {
+	var err error
+	if len(batch) > 0 {
+		for attempt := 0; attempt < sendAttempts; attempt++ {
+			if attempt > 0 {
+				time.Sleep(m.retryDelay << (attempt - 1))
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			err = m.send(ctx, &w.drain, batch)
+			cancel()
+			if err == nil {
+				break
+			}
+		}
+	}
+	m.recordDelivery(w.drain.ID, len(batch), w.dropped.Swap(0), err)
+}
+
+func (m *Manager) recordDelivery(drainID uint, sent int, dropped int64, err error) {
+	now := time.Now()
+	updates := map[string]interface{}{}
+	if dropped > 0 {
+		updates["dropped_count"] = gorm.Expr("dropped_count + ?", dropped)
+	}
+	switch {
+	case sent == 0:
+	case err != nil:
+		message := err.Error()
+		if len(message) > 1000 {
+			message = message[:1000]
+		}
+		updates["failed_count"] = gorm.Expr("failed_count + ?", sent)
+		updates["status"] = StatusFailing
+		updates["last_error"] = message
+		updates["last_error_at"] = now
+	default:
+		updates["delivered_count"] = gorm.Expr("delivered_count + ?", sent)
+		updates["status"] = StatusHealthy
+		updates["last_delivery_at"] = now
+	}
+	if len(updates) > 0 {
+		m.db.Model(&Drain{}).Where("id = ?", drainID).UpdateColumns(updates)
+	}
+}
+
+// List returns a project's drains
+func (m *Manager) List(projectID uint) ([]Drain, error) {
+	var drains []Drain
+	err := m.db.Where("project_id = ?", projectID).Order("id").Find(&drains).Error
+	return drains, err
+}
+
+// Get returns one of a project's drains
+func (m *Manager) Get(projectID, drainID uint) (*Drain, error) {
+	var drain Drain
+	if err := m.db.Where("id = ? AND project_id = ?", drainID, projectID).First(&drain).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDrainNotFound
+		}
+		return nil, err
+	}
+	return &drain, nil
+}
+
+// Create validates and stores a drain
+func (m *Manager) Create(drain *Drain) error {
+	if err := drain.Normalize(); err != nil {
+		return err
+	}
+	var count int64
+	if err := m.db.Model(&Drain{}).Where("project_id = ?", drain.ProjectID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= MaxDrainsPerProject {
+		return fmt.Errorf("%w: at most %d drains per project", ErrInvalidDrain, MaxDrainsPerProject)
+	}
+	drain.Status = StatusPending
+	if err := m.db.Create(drain).Error; err != nil {
+		return err
+	}
+	if !drain.Enabled {
+		// The column default would otherwise turn a false Enabled into true
+		m.db.Model(drain).Update("enabled", false)
+	}
+	drain.HasToken = drain.Token != ""
+	m.reload(drain.ProjectID)
+	return nil
+}
+
+// Update applies changes to a drain
+func (m *Manager) Update(projectID, drainID uint, update DrainUpdate) (*Drain, error) {
+	drain, err := m.Get(projectID, drainID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Name != nil {
+		drain.Name = *update.Name
+	}
+	if update.URL != nil {
+		drain.URL = *update.URL
+	}
+	if update.Token != nil {
+		drain.Token = *update.Token
+	}
+	if update.Sources != nil {
+		drain.Sources = *update.Sources
+	}
+	if update.DeploymentID != nil {
+		drain.DeploymentID = *update.DeploymentID
+	}
+	if update.Enabled != nil {
+		drain.Enabled = *update.Enabled
+	}
+	if err := drain.Normalize(); err != nil {
+		return nil, err
+	}
+	if err := m.db.Select("name", "url", "token", "sources", "deployment_id", "enabled").Save(drain).Error; err != nil {
+		return nil, err
+	}
+	drain.HasToken = drain.Token != ""
+	m.reload(projectID)
+	return drain, nil
+}
+
+// Delete removes a drain
+func (m *Manager) Delete(projectID, drainID uint) error {
+	result := m.db.Where("id = ? AND project_id = ?", drainID, projectID).Delete(&Drain{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDrainNotFound
+	}
+	m.reload(projectID)
+	return nil
+}
+
+// Test sends one record to a drain right away and records the outcome
+func (m *Manager) Test(ctx context.Context, projectID, drainID uint) (*Drain, error) {
+	drain, err := m.Get(projectID, drainID)
+	if err != nil {
+		return nil, err
+	}
+	batch := []Record{{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Service:   SourceHosting,
+		Source:    "log-drain",
+		Message:   fmt.Sprintf("APEX log drain %q test message", drain.Name),
+		ProjectID: projectID,
+	}}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	sendErr := m.send(ctx, drain, batch)
+	m.recordDelivery(drain.ID, 1, 0, sendErr)
+	updated, err := m.Get(projectID, drainID)
+	if err != nil {
+		return nil, err
+	}
+	return updated, sendErr
+}
@@ -0,0 +1,175 @@
+package logdrain
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupManager(t *testing.T) (*Manager, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Drain{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	manager := NewManager(db)
+	manager.retryDelay = time.Millisecond
+	return manager, db
+}
+
+func TestNormalizeValidatesSinks(t *testing.T) {
+	cases := []struct {
+		drain Drain
+		ok    bool
+	}{
+		{Drain{Name: "api", Type: TypeHTTPS, URL: "https://logs.example.com/ingest"}, true},
+		{Drain{Name: "api", Type: TypeHTTPS, URL: "http://logs.example.com/ingest"}, false},
+		{Drain{Name: "papertrail", Type: TypeSyslog, URL: "syslog+tls://logs.papertrailapp.com:12345"}, true},
+		{Drain{Name: "papertrail", Type: TypeSyslog, URL: "syslog://logs.papertrailapp.com"}, false},
+		{Drain{Name: "dd", Type: TypeDatadog, Token: "key"}, true},
+		{Drain{Name: "dd", Type: TypeDatadog}, false},
+		{Drain{Name: "Bad Name", Type: TypeLogtail, Token: "t"}, false},
+		{Drain{Name: "bs", Type: TypeLogtail, Token: "t", Sources: "everything"}, false},
+	}
+	for _, tc := range cases {
+		err := tc.drain.Normalize()
+		if (err == nil) != tc.ok {
+			t.Errorf("%s %s: err = %v, want ok = %v", tc.drain.Type, tc.drain.URL, err, tc.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidDrain) {
+			t.Errorf("error should wrap ErrInvalidDrain: %v", err)
+		}
+	}
+}
+
+func TestManagerBatchesToHTTPSAndReportsStatus(t *testing.T) {
+	manager, _ := setupManager(t)
+
+	var mu sync.Mutex
+	var received []Record
+	var auth string
+	sink := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []Record
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		received = append(received, batch...)
+		auth = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer sink.Close()
+	manager.client = sink.Client()
+
+	drain := &Drain{ProjectID: 3, UserID: 1, Name: "collector", Type: TypeHTTPS, URL: sink.URL, Token: "secret", Sources: SourceHosting, Enabled: true}
+	if err := manager.Create(drain); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for i := 0; i < BatchSize+5; i++ {
+		manager.ForwardDeploymentLog(3, "dep-1", time.Now(), "info", "runtime", "line "+strconv.Itoa(i))
+	}
+	manager.ForwardPreviewLog(3, "stdout", "preview lines are filtered out")
+	manager.ForwardDeploymentLog(4, "dep-2", time.Now(), "info", "runtime", "other project")
+	manager.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != BatchSize+5 {
+		t.Fatalf("received %d records, want %d", len(received), BatchSize+5)
+	}
+	if received[0].DeploymentID != "dep-1" || received[0].Service != SourceHosting || auth != "Bearer secret" {
+		t.Fatalf("record = %+v, auth = %q", received[0], auth)
+	}
+
+	stored, err := manager.Get(3, drain.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Status != StatusHealthy || stored.DeliveredCount != int64(BatchSize+5) || !stored.HasToken {
+		t.Fatalf("drain status = %+v", stored)
+	}
+}
+
+func TestManagerSyslogFramingAndFailures(t *testing.T) {
+	manager, _ := setupManager(t)
+	manager.dialer.Control = nil
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	lines := make(chan string, 4)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			message := make([]byte, n)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			lines <- string(message)
+		}
+	}()
+
+	drain := &Drain{ProjectID: 5, UserID: 1, Name: "syslog", Type: TypeSyslog, URL: "syslog://" + listener.Addr().String(), Sources: SourcePreview, Enabled: true}
+	if err := manager.Create(drain); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := manager.Test(context.Background(), 5, drain.ID); err != nil {
+		t.Fatalf("test delivery: %v", err)
+	}
+	select {
+	case line := <-lines:
+		if !strings.HasPrefix(line, "<14>1 ") || !strings.Contains(line, " project-5 log-drain - ") {
+			t.Fatalf("syslog line = %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("syslog sink received nothing")
+	}
+
+	listener.Close()
+	failing, err := manager.Test(context.Background(), 5, drain.ID)
+	if err == nil {
+		t.Fatal("delivery to a closed sink should fail")
+	}
+	if failing.Status != StatusFailing || failing.FailedCount != 1 || failing.LastError == "" {
+		t.Fatalf("drain status = %+v", failing)
+	}
+}
+
+func TestManagerRefusesInternalSinks(t *testing.T) {
+	manager, _ := setupManager(t)
+	drain := &Drain{ProjectID: 6, UserID: 1, Name: "local", Type: TypeSyslog, URL: "syslog://127.0.0.1:514", Enabled: true}
+	if err := manager.Create(drain); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_, err := manager.Test(context.Background(), 6, drain.ID)
+	if err == nil || !strings.Contains(err.Error(), "internal addresses") {
+		t.Fatalf("err = %v, want internal address refusal", err)
+	}
+}
@@ -0,0 +1,179 @@
+package logdrain
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one forwarded log line
+type Record struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Level        string    `json:"level"`
+	Service      string    `json:"service"` // hosting or preview
+	Source       string    `json:"source"`  // build, deploy, runtime, worker:<name>, stdout, stderr
+	Message      string    `json:"message"`
+	ProjectID    uint      `json:"project_id"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+}
+
+// host names the process that produced the record
+func (r *Record) host() string {
+	if r.DeploymentID != "" {
+		return "apex-" + r.DeploymentID
+	}
+	return fmt.Sprintf("apex-preview-%d", r.ProjectID)
+}
+
+// send delivers a batch to the drain's sink
+func (m *Manager) send(ctx context.Context, drain *Drain, batch []Record) error {
+	switch drain.Type {
+	case TypeSyslog:
+		return m.sendSyslog(ctx, drain, batch)
+	case TypeDatadog:
+		entries := make([]map[string]any, len(batch))
+		for i, record := range batch {
+			tags := fmt.Sprintf("project_id:%d,service_type:%s,source:%s", record.ProjectID, record.Service, record.Source)
+			if record.DeploymentID != "" {
+				tags += ",deployment_id:" + record.DeploymentID
+			}
+			entries[i] = map[string]any{
+				"ddsource": "apex-build",
+				"service":  fmt.Sprintf("project-%d", record.ProjectID),
+				"hostname": record.host(),
+				"status":   record.Level,
+				"message":  record.Message,
+				"ddtags":   tags,
+				"date":     record.Timestamp.UnixMilli(),
+			}
+		}
+		return m.postJSON(ctx, drain.URL, map[string]string{"DD-API-KEY": drain.Token}, entries)
+	case TypeLogtail:
+		entries := make([]map[string]any, len(batch))
+		for i, record := range batch {
+			entries[i] = map[string]any{
+				"dt":            record.Timestamp.UTC().Format(time.RFC3339Nano),
+				"level":         record.Level,
+				"message":       record.Message,
+				"service":       record.Service,
+				"source":        record.Source,
+				"project_id":    record.ProjectID,
+				"deployment_id": record.DeploymentID,
+			}
+		}
+		return m.postJSON(ctx, drain.URL, map[string]string{"Authorization": "Bearer " + drain.Token}, entries)
+	default:
+		headers := map[string]string{}
+		if drain.Token != "" {
+			headers["Authorization"] = "Bearer " + drain.Token
+		}
+		return m.postJSON(ctx, drain.URL, headers, batch)
+	}
+}
+
+func (m *Manager) postJSON(ctx context.Context, endpoint string, headers map[string]string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "apex-build-log-drain")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("sink returned %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// sendSyslog writes the batch as RFC 5424 messages with octet-counting
+// framing (RFC 6587), over one connection
+func (m *Manager) sendSyslog(ctx context.Context, drain *Drain, batch []Record) error {
+	endpoint, err := url.Parse(drain.URL)
+	if err != nil {
+		return err
+	}
+	var conn net.Conn
+	if endpoint.Scheme == "syslog+tls" {
+		conn, err = (&tls.Dialer{NetDialer: m.dialer, Config: &tls.Config{MinVersion: tls.VersionTLS12}}).DialContext(ctx, "tcp", endpoint.Host)
+	} else {
+		conn, err = m.dialer.DialContext(ctx, "tcp", endpoint.Host)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+
+	var buf bytes.Buffer
+	for i := range batch {
+		message := syslogMessage(&batch[i], drain.Token)
+		buf.WriteString(strconv.Itoa(len(message)))
+		buf.WriteByte(' ')
+		buf.WriteString(message)
+	}
+	_, err = conn.Write(buf.Bytes())
+	return err
+}
+
+// syslogMessage formats a record as RFC 5424 with the user facility. A
+// token, when set, is sent as structured data, as hosted syslog services
+// expect.
+func syslogMessage(record *Record, token string) string {
+	severity := 6 // informational
+	switch strings.ToLower(record.Level) {
+	case "error", "fatal", "critical":
+		severity = 3
+	case "warn", "warning":
+		severity = 4
+	case "debug":
+		severity = 7
+	}
+	structured := "-"
+	if token != "" {
+		structured = `[auth@41058 token="` + syslogEscape(token) + `"]`
+	}
+	procID := strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 {
+			return '-'
+		}
+		return r
+	}, record.Source)
+	if procID == "" {
+		procID = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s project-%d %s - %s %s",
+		8+severity,
+		record.Timestamp.UTC().Format(time.RFC3339Nano),
+		record.host(),
+		record.ProjectID,
+		procID,
+		structured,
+		strings.TrimRight(record.Message, "\r\n"))
+}
+
+func syslogEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
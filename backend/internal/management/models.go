@@ -0,0 +1,159 @@
+// Package management is the declarative management API used by
+// infrastructure-as-code tools such as the APEX.BUILD Terraform provider.
+// Resources are addressed by stable external IDs chosen by the caller
+// (organization slug, member email, role name, webhook ID) and written with
+// idempotent PUTs, so applying the same configuration twice changes nothing.
+// Clients authenticate with personal management tokens instead of session
+// JWTs.
+package management
+
+import (
+	"errors"
+	"time"
+)
+
+// TokenPrefix starts every management token
+const TokenPrefix = "apx_mgmt_"
+
+const (
+	// MaxTokensPerUser caps active management tokens per user
+	MaxTokensPerUser = 20
+	// MaxWebhooksPerOrganization caps webhook configs per organization
+	MaxWebhooksPerOrganization = 20
+	// MaxWebhookEvents caps the event filters of one webhook
+	MaxWebhookEvents = 50
+	// OwnerRoleName is the role given to the creator of an organization.
+	// It is managed by the platform and cannot be changed through the API.
+	OwnerRoleName = "Owner"
+	// ProvisionedByManagement marks memberships created through the API
+	ProvisionedByManagement = "management"
+)
+
+var (
+	ErrNotFound          = errors.New("resource not found")
+	ErrForbidden         = errors.New("permission denied")
+	ErrInvalid           = errors.New("invalid resource")
+	ErrConflict          = errors.New("resource conflicts with an existing one")
+	ErrUserNotFound      = errors.New("no user has this email address")
+	ErrMemberLimit       = errors.New("organization member limit reached")
+	ErrLastOwner         = errors.New("cannot remove the last member who can manage the organization")
+	ErrRoleInUse         = errors.New("role is assigned to members")
+	ErrReservedRole      = errors.New("the Owner role is managed by the platform")
+	ErrQuotaAboveCeiling = errors.New("quota exceeds the organization's plan; contact support to raise it")
+	ErrTokenLimit        = errors.New("management token limit reached")
+	ErrInvalidToken      = errors.New("invalid or expired management token")
+)
+
+// Token is a personal management API token. Only its hash is stored.
+type Token struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName specifies the table name for Token
+func (Token) TableName() string {
+	return "management_tokens"
+}
+
+// Webhook delivers an organization's audit events to an HTTPS endpoint
+type Webhook struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uint   `json:"-" gorm:"not null;uniqueIndex:idx_org_webhook_external_id"`
+	ExternalID     string `json:"external_id" gorm:"size:64;not null;uniqueIndex:idx_org_webhook_external_id"`
+	URL            string `json:"url" gorm:"size:500;not null"`
+	// Audit actions to deliver; "*" delivers everything
+	Events  []string `json:"events" gorm:"serializer:json"`
+	Secret  string   `json:"-" gorm:"size:200"` // HMAC signing secret
+	Enabled bool     `json:"enabled"`
+
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	LastStatus     int        `json:"last_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty" gorm:"size:500"`
+}
+
+// TableName specifies the table name for Webhook
+func (Webhook) TableName() string {
+	return "organization_webhooks"
+}
+
+// OrganizationSpec is the desired state of an organization
+type OrganizationSpec struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Website      string `json:"website"`
+	BillingEmail string `json:"billing_email"`
+}
+
+// OrganizationResource is an organization as the management API sees it
+type OrganizationResource struct {
+	ExternalID       string    `json:"external_id"`
+	ID               uint      `json:"id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Website          string    `json:"website"`
+	BillingEmail     string    `json:"billing_email"`
+	SubscriptionType string    `json:"subscription_type"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// MemberSpec is the desired state of a membership
+type MemberSpec struct {
+	Role string `json:"role"`
+}
+
+// MemberResource is a membership keyed by the member's email
+type MemberResource struct {
+	ExternalID    string    `json:"external_id"`
+	UserID        uint      `json:"user_id"`
+	Username      string    `json:"username"`
+	Role          string    `json:"role"`
+	Status        string    `json:"status"`
+	ProvisionedBy string    `json:"provisioned_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RoleSpec is the desired state of a custom role. Permissions are
+// "resource:action" pairs.
+type RoleSpec struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	IsDefault   bool     `json:"is_default"`
+}
+
+// RoleResource is a role keyed by its name
+type RoleResource struct {
+	ExternalID  string   `json:"external_id"`
+	ID          uint     `json:"id"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	IsDefault   bool     `json:"is_default"`
+	Managed     bool     `json:"managed"`
+}
+
+// Quotas are an organization's limits
+type Quotas struct {
+	MaxMembers    int     `json:"max_members"`
+	MaxProjects   int     `json:"max_projects"`
+	MaxStorageGB  float64 `json:"max_storage_gb"`
+	MaxAIRequests int     `json:"max_ai_requests"`
+}
+
+// WebhookSpec is the desired state of a webhook. An empty secret keeps the
+// current one.
+type WebhookSpec struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Secret  string   `json:"secret"`
+	Enabled *bool    `json:"enabled"`
+}
@@ -0,0 +1,717 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	slugPattern       = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+	roleNamePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,62}$`)
+	externalIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+)
+
+// Resources and actions a role permission may name
+var (
+	permissionResources = map[string]bool{"organization": true, "roles": true, "projects": true, "audit_logs": true, "billing": true}
+	permissionActions   = map[string]bool{"read": true, "create": true, "update": true, "delete": true, "manage": true}
+)
+
+// ownerPermissions are granted to the Owner role of new organizations
+var ownerPermissions = []string{"organization:manage", "roles:manage", "projects:manage", "audit_logs:read", "billing:manage"}
+
+// planCeilings cap the quotas organization managers may set for
+// themselves; platform admins may exceed them. Plans without an entry use
+// the team ceilings, and enterprise has none.
+var planCeilings = map[string]*Quotas{
+	"team":       {MaxMembers: 15, MaxProjects: 100, MaxStorageGB: 50, MaxAIRequests: 10000},
+	"enterprise": nil,
+}
+
+// Actor is the caller of a management operation
+type Actor struct {
+	UserID uint
+	// Admin is a platform admin, who may manage any organization
+	Admin bool
+}
+
+// Service applies declarative changes to organizations and their members,
+// roles, quotas and webhooks
+type Service struct {
+	db     *gorm.DB
+	rbac   *enterprise.RBACService
+	audit  *enterprise.AuditService
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewService creates a management service. audit may be nil.
+func NewService(db *gorm.DB, rbac *enterprise.RBACService, audit *enterprise.AuditService) *Service {
+	return &Service{
+		db:     db,
+		rbac:   rbac,
+		audit:  audit,
+		client: newWebhookClient(),
+		now:    time.Now,
+	}
+}
+
+// AutoMigrate creates the management tables
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&Token{}, &Webhook{})
+}
+
+func (s *Service) can(actor Actor, orgID uint, resource, action string) bool {
+	return actor.Admin || s.rbac.HasPermission(orgID, actor.UserID, resource, action)
+}
+
+func (s *Service) organization(slug string) (*enterprise.Organization, error) {
+	var org enterprise.Organization
+	err := s.db.Where("slug = ?", slug).First(&org).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// authorize loads the organization and checks the actor's permission
+func (s *Service) authorize(actor Actor, slug, resource, action string) (*enterprise.Organization, error) {
+	org, err := s.organization(slug)
+	if err != nil {
+		return nil, err
+	}
+	if !s.can(actor, org.ID, resource, action) {
+		return nil, ErrForbidden
+	}
+	return org, nil
+}
+
+func (s *Service) record(org *enterprise.Organization, actor Actor, action, resourceType, resourceID, description string, values map[string]interface{}) {
+	if s.audit == nil {
+		return
+	}
+	s.audit.LogEvent(&enterprise.AuditLog{
+		OrganizationID: &org.ID,
+		UserID:         &actor.UserID,
+		Action:         action,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+		ResourceName:   org.Name,
+		Category:       "system",
+		Description:    description,
+		NewValue:       values,
+		Metadata:       map[string]interface{}{"via": "management_api"},
+	})
+}
+
+func organizationResource(org *enterprise.Organization) *OrganizationResource {
+	return &OrganizationResource{
+		ExternalID:       org.Slug,
+		ID:               org.ID,
+		Name:             org.Name,
+		Description:      org.Description,
+		Website:          org.Website,
+		BillingEmail:     org.BillingEmail,
+		SubscriptionType: org.SubscriptionType,
+		CreatedAt:        org.CreatedAt,
+		UpdatedAt:        org.UpdatedAt,
+	}
+}
+
+// GetOrganization returns an organization by slug
+func (s *Service) GetOrganization(actor Actor, slug string) (*OrganizationResource, error) {
+	org, err := s.authorize(actor, slug, "organization", "read")
+	if err != nil {
+		return nil, err
+	}
+	return organizationResource(org), nil
+}
+
+// ApplyOrganization creates the organization with the actor as its Owner,
+// or updates it to match the spec. It reports whether it was created.
+func (s *Service) ApplyOrganization(actor Actor, slug string, spec OrganizationSpec) (*OrganizationResource, bool, error) {
+	spec.Name = strings.TrimSpace(spec.Name)
+	if !slugPattern.MatchString(slug) {
+		return nil, false, fmt.Errorf("%w: slug must be 2-63 lowercase letters, digits or hyphens", ErrInvalid)
+	}
+	if spec.Name == "" || len(spec.Name) > 100 {
+		return nil, false, fmt.Errorf("%w: name is required (max 100 characters)", ErrInvalid)
+	}
+
+	org, err := s.organization(slug)
+	if errors.Is(err, ErrNotFound) {
+		org, err = s.createOrganization(actor, slug, spec)
+		if err != nil {
+			return nil, false, err
+		}
+		s.record(org, actor, "organization_created", "organization", strconv.FormatUint(uint64(org.ID), 10), "Organization created", map[string]interface{}{"slug": slug, "name": org.Name})
+		return organizationResource(org), true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !s.can(actor, org.ID, "organization", "manage") {
+		return nil, false, ErrForbidden
+	}
+
+	changes := map[string]interface{}{}
+	for column, pair := range map[string][2]string{
+		"name":          {org.Name, spec.Name},
+		"description":   {org.Description, spec.Description},
+		"website":       {org.Website, spec.Website},
+		"billing_email": {org.BillingEmail, spec.BillingEmail},
+	} {
+		if pair[0] != pair[1] {
+			changes[column] = pair[1]
+		}
+	}
+	if len(changes) == 0 {
+		return organizationResource(org), false, nil
+	}
+	if name, ok := changes["name"]; ok {
+		var taken int64
+		s.db.Model(&enterprise.Organization{}).Unscoped().Where("name = ? AND id <> ?", name, org.ID).Count(&taken)
+		if taken > 0 {
+			return nil, false, fmt.Errorf("%w: organization name %q is taken", ErrConflict, name)
+		}
+	}
+	if err := s.db.Model(org).Updates(changes).Error; err != nil {
+		return nil, false, err
+	}
+	if err := s.db.First(org, org.ID).Error; err != nil {
+		return nil, false, err
+	}
+	s.record(org, actor, "organization_updated", "organization", strconv.FormatUint(uint64(org.ID), 10), "Organization updated", changes)
+	return organizationResource(org), false, nil
+}
+
+func (s *Service) createOrganization(actor Actor, slug string, spec OrganizationSpec) (*enterprise.Organization, error) {
+	var taken int64
+	s.db.Model(&enterprise.Organization{}).Unscoped().Where("slug = ? OR name = ?", slug, spec.Name).Count(&taken)
+	if taken > 0 {
+		return nil, fmt.Errorf("%w: an organization with this slug or name already exists or was deleted", ErrConflict)
+	}
+
+	org := &enterprise.Organization{
+		Name:         spec.Name,
+		Slug:         slug,
+		Description:  spec.Description,
+		Website:      spec.Website,
+		BillingEmail: spec.BillingEmail,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		perms, err := permissionRows(tx, ownerPermissions)
+		if err != nil {
+			return err
+		}
+		owner := &enterprise.Role{
+			OrganizationID: &org.ID,
+			Name:           OwnerRoleName,
+			Description:    "Full control of the organization",
+			Permissions:    perms,
+		}
+		if err := tx.Create(owner).Error; err != nil {
+			return err
+		}
+		now := s.now()
+		return tx.Create(&enterprise.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         actor.UserID,
+			RoleID:         owner.ID,
+			Status:         "active",
+			ProvisionedBy:  ProvisionedByManagement,
+			JoinedAt:       &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// permissionRows finds or creates the permissions named "resource:action"
+func permissionRows(tx *gorm.DB, names []string) ([]enterprise.Permission, error) {
+	perms := make([]enterprise.Permission, 0, len(names))
+	for _, name := range names {
+		resource, action, _ := strings.Cut(name, ":")
+		perm := enterprise.Permission{Name: name, Resource: resource, Action: action, Scope: "organization"}
+		if err := tx.Where("name = ?", name).FirstOrCreate(&perm).Error; err != nil {
+			return nil, err
+		}
+		perms = append(perms, perm)
+	}
+	return perms, nil
+}
+
+func normalizePermissions(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		resource, action, ok := strings.Cut(name, ":")
+		if !ok || !permissionResources[resource] || !permissionActions[action] {
+			return nil, fmt.Errorf("%w: unknown permission %q", ErrInvalid, name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// managers counts active members whose role can manage the organization
+func managers(tx *gorm.DB, orgID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&enterprise.OrganizationMember{}).
+		Joins("JOIN role_permissions rp ON rp.role_id = organization_members.role_id").
+		Joins("JOIN permissions p ON p.id = rp.permission_id").
+		Where("organization_members.organization_id = ? AND organization_members.status = ?", orgID, "active").
+		Where("p.resource = ? AND p.action = ?", "organization", "manage").
+		Distinct("organization_members.id").Count(&count).Error
+	return count, err
+}
+
+func (s *Service) canManage(tx *gorm.DB, roleID uint) bool {
+	var count int64
+	tx.Table("role_permissions rp").Joins("JOIN permissions p ON p.id = rp.permission_id").
+		Where("rp.role_id = ? AND p.resource = ? AND p.action = ?", roleID, "organization", "manage").Count(&count)
+	return count > 0
+}
+
+type memberRow struct {
+	enterprise.OrganizationMember
+	Email    string
+	Username string
+	RoleName string
+}
+
+func (s *Service) memberQuery(orgID uint) *gorm.DB {
+	return s.db.Model(&enterprise.OrganizationMember{}).
+		Select("organization_members.*, u.email, u.username, r.name AS role_name").
+		Joins("JOIN users u ON u.id = organization_members.user_id").
+		Joins("LEFT JOIN roles r ON r.id = organization_members.role_id").
+		Where("organization_members.organization_id = ?", orgID)
+}
+
+func memberResource(row *memberRow) *MemberResource {
+	return &MemberResource{
+		ExternalID:    strings.ToLower(row.Email),
+		UserID:        row.UserID,
+		Username:      row.Username,
+		Role:          row.RoleName,
+		Status:        row.Status,
+		ProvisionedBy: row.ProvisionedBy,
+		CreatedAt:     row.CreatedAt,
+	}
+}
+
+func (s *Service) member(orgID uint, email string) (*memberRow, error) {
+	var row memberRow
+	err := s.memberQuery(orgID).Where("LOWER(u.email) = ?", strings.ToLower(email)).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// ListMembers returns the organization's members
+func (s *Service) ListMembers(actor Actor, slug string) ([]*MemberResource, error) {
+	org, err := s.authorize(actor, slug, "organization", "read")
+	if err != nil {
+		return nil, err
+	}
+	var rows []memberRow
+	if err := s.memberQuery(org.ID).Order("organization_members.id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*MemberResource, 0, len(rows))
+	for i := range rows {
+		out = append(out, memberResource(&rows[i]))
+	}
+	return out, nil
+}
+
+// GetMember returns a member by email
+func (s *Service) GetMember(actor Actor, slug, email string) (*MemberResource, error) {
+	org, err := s.authorize(actor, slug, "organization", "read")
+	if err != nil {
+		return nil, err
+	}
+	row, err := s.member(org.ID, email)
+	if err != nil {
+		return nil, err
+	}
+	return memberResource(row), nil
+}
+
+func (s *Service) role(orgID uint, name string) (*enterprise.Role, error) {
+	var role enterprise.Role
+	err := s.db.Preload("Permissions").Where("organization_id = ? AND name = ?", orgID, name).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// ApplyMember adds an existing user to the organization with the role, or
+// changes their role. It reports whether the membership was created.
+func (s *Service) ApplyMember(actor Actor, slug, email string, spec MemberSpec) (*MemberResource, bool, error) {
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return nil, false, err
+	}
+	role, err := s.role(org.ID, strings.TrimSpace(spec.Role))
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, fmt.Errorf("%w: role %q does not exist in the organization", ErrInvalid, spec.Role)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, err := s.member(org.ID, email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+	created := existing == nil
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if existing != nil {
+			if existing.RoleID == role.ID {
+				return nil
+			}
+			if s.canManage(tx, existing.RoleID) && !s.canManage(tx, role.ID) {
+				count, err := managers(tx, org.ID)
+				if err != nil {
+					return err
+				}
+				if count <= 1 {
+					return ErrLastOwner
+				}
+			}
+			return tx.Model(&enterprise.OrganizationMember{}).Where("id = ?", existing.ID).Update("role_id", role.ID).Error
+		}
+
+		var user models.User
+		if err := tx.Select("id").Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		var count int64
+		tx.Model(&enterprise.OrganizationMember{}).Where("organization_id = ?", org.ID).Count(&count)
+		if org.MaxMembers > 0 && int(count) >= org.MaxMembers {
+			return ErrMemberLimit
+		}
+		now := s.now()
+		return tx.Create(&enterprise.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			RoleID:         role.ID,
+			Status:         "active",
+			ProvisionedBy:  ProvisionedByManagement,
+			InvitedBy:      &actor.UserID,
+			JoinedAt:       &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	row, err := s.member(org.ID, email)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		s.record(org, actor, "member_added", "member", strconv.FormatUint(uint64(row.UserID), 10), "Member added", map[string]interface{}{"email": row.Email, "role": role.Name})
+	} else if existing.RoleID != role.ID {
+		s.record(org, actor, "member_role_changed", "member", strconv.FormatUint(uint64(row.UserID), 10), "Member role changed", map[string]interface{}{"email": row.Email, "role": role.Name})
+	}
+	return memberResource(row), created, nil
+}
+
+// DeleteMember removes a member from the organization
+func (s *Service) DeleteMember(actor Actor, slug, email string) error {
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return err
+	}
+	existing, err := s.member(org.ID, email)
+	if err != nil {
+		return err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if s.canManage(tx, existing.RoleID) && existing.Status == "active" {
+			count, err := managers(tx, org.ID)
+			if err != nil {
+				return err
+			}
+			if count <= 1 {
+				return ErrLastOwner
+			}
+		}
+		return tx.Delete(&enterprise.OrganizationMember{}, existing.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.record(org, actor, "member_removed", "member", strconv.FormatUint(uint64(existing.UserID), 10), "Member removed", map[string]interface{}{"email": existing.Email})
+	return nil
+}
+
+func roleResource(role *enterprise.Role) *RoleResource {
+	perms := make([]string, 0, len(role.Permissions))
+	for _, p := range role.Permissions {
+		perms = append(perms, p.Resource+":"+p.Action)
+	}
+	sort.Strings(perms)
+	return &RoleResource{
+		ExternalID:  role.Name,
+		ID:          role.ID,
+		Description: role.Description,
+		Permissions: perms,
+		IsDefault:   role.IsDefault,
+		Managed:     strings.EqualFold(role.Name, OwnerRoleName),
+	}
+}
+
+func (s *Service) authorizeRoleRead(actor Actor, slug string) (*enterprise.Organization, error) {
+	org, err := s.organization(slug)
+	if err != nil {
+		return nil, err
+	}
+	if !s.can(actor, org.ID, "roles", "read") && !s.can(actor, org.ID, "organization", "read") {
+		return nil, ErrForbidden
+	}
+	return org, nil
+}
+
+// ListRoles returns the organization's roles
+func (s *Service) ListRoles(actor Actor, slug string) ([]*RoleResource, error) {
+	org, err := s.authorizeRoleRead(actor, slug)
+	if err != nil {
+		return nil, err
+	}
+	var roles []enterprise.Role
+	if err := s.db.Preload("Permissions").Where("organization_id = ?", org.ID).Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*RoleResource, 0, len(roles))
+	for i := range roles {
+		out = append(out, roleResource(&roles[i]))
+	}
+	return out, nil
+}
+
+// GetRole returns a role by name
+func (s *Service) GetRole(actor Actor, slug, name string) (*RoleResource, error) {
+	org, err := s.authorizeRoleRead(actor, slug)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.role(org.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	return roleResource(role), nil
+}
+
+// ApplyRole creates or replaces a custom role. It reports whether the role
+// was created.
+func (s *Service) ApplyRole(actor Actor, slug, name string, spec RoleSpec) (*RoleResource, bool, error) {
+	if strings.EqualFold(name, OwnerRoleName) {
+		return nil, false, ErrReservedRole
+	}
+	if !roleNamePattern.MatchString(name) {
+		return nil, false, fmt.Errorf("%w: role names are 1-63 letters, digits, spaces, dots, underscores or hyphens", ErrInvalid)
+	}
+	perms, err := normalizePermissions(spec.Permissions)
+	if err != nil {
+		return nil, false, err
+	}
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return nil, false, err
+	}
+
+	role, err := s.role(org.ID, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+	created := role == nil
+	if !created {
+		current := roleResource(role)
+		if current.Description == spec.Description && current.IsDefault == spec.IsDefault &&
+			strings.Join(current.Permissions, ",") == strings.Join(perms, ",") {
+			return current, false, nil
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		rows, err := permissionRows(tx, perms)
+		if err != nil {
+			return err
+		}
+		if created {
+			role = &enterprise.Role{OrganizationID: &org.ID, Name: name}
+		} else if s.canManage(tx, role.ID) && !containsString(perms, "organization:manage") {
+			// Dropping manage from a role must leave someone who can manage
+			var holders, total int64
+			tx.Model(&enterprise.OrganizationMember{}).Where("organization_id = ? AND role_id = ? AND status = ?", org.ID, role.ID, "active").Count(&holders)
+			if total, err = managers(tx, org.ID); err != nil {
+				return err
+			}
+			if holders > 0 && total-holders < 1 {
+				return ErrLastOwner
+			}
+		}
+		role.Description = spec.Description
+		role.IsDefault = spec.IsDefault
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(role).Association("Permissions").Replace(rows); err != nil {
+			return err
+		}
+		if spec.IsDefault {
+			return tx.Model(&enterprise.Role{}).Where("organization_id = ? AND id <> ?", org.ID, role.ID).Update("is_default", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	role, err = s.role(org.ID, name)
+	if err != nil {
+		return nil, false, err
+	}
+	action := "role_updated"
+	if created {
+		action = "role_created"
+	}
+	s.record(org, actor, action, "role", strconv.FormatUint(uint64(role.ID), 10), "Role "+name+" saved", map[string]interface{}{"role": name, "permissions": perms, "is_default": spec.IsDefault})
+	return roleResource(role), created, nil
+}
+
+// DeleteRole deletes a custom role that no member holds
+func (s *Service) DeleteRole(actor Actor, slug, name string) error {
+	if strings.EqualFold(name, OwnerRoleName) {
+		return ErrReservedRole
+	}
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return err
+	}
+	role, err := s.role(org.ID, name)
+	if err != nil {
+		return err
+	}
+	var holders int64
+	s.db.Model(&enterprise.OrganizationMember{}).Where("organization_id = ? AND role_id = ?", org.ID, role.ID).Count(&holders)
+	if holders > 0 {
+		return ErrRoleInUse
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.record(org, actor, "role_deleted", "role", strconv.FormatUint(uint64(role.ID), 10), "Role "+name+" deleted", map[string]interface{}{"role": name})
+	return nil
+}
+
+func quotasOf(org *enterprise.Organization) *Quotas {
+	return &Quotas{
+		MaxMembers:    org.MaxMembers,
+		MaxProjects:   org.MaxProjects,
+		MaxStorageGB:  org.MaxStorageGB,
+		MaxAIRequests: org.MaxAIRequests,
+	}
+}
+
+// GetQuotas returns the organization's limits
+func (s *Service) GetQuotas(actor Actor, slug string) (*Quotas, error) {
+	org, err := s.authorize(actor, slug, "organization", "read")
+	if err != nil {
+		return nil, err
+	}
+	return quotasOf(org), nil
+}
+
+// ApplyQuotas sets the organization's limits. Organization managers may
+// set limits up to their plan's ceiling; platform admins may exceed it.
+func (s *Service) ApplyQuotas(actor Actor, slug string, q Quotas) (*Quotas, error) {
+	if q.MaxMembers < 1 || q.MaxProjects < 1 || q.MaxStorageGB <= 0 || q.MaxAIRequests < 1 {
+		return nil, fmt.Errorf("%w: every quota must be positive", ErrInvalid)
+	}
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Admin {
+		ceiling, ok := planCeilings[org.SubscriptionType]
+		if !ok {
+			ceiling = planCeilings["team"]
+		}
+		if ceiling != nil && (q.MaxMembers > ceiling.MaxMembers || q.MaxProjects > ceiling.MaxProjects ||
+			q.MaxStorageGB > ceiling.MaxStorageGB || q.MaxAIRequests > ceiling.MaxAIRequests) {
+			return nil, ErrQuotaAboveCeiling
+		}
+	}
+	if *quotasOf(org) == q {
+		return &q, nil
+	}
+	err = s.db.Model(org).Updates(map[string]interface{}{
+		"max_members":     q.MaxMembers,
+		"max_projects":    q.MaxProjects,
+		"max_storage_gb":  q.MaxStorageGB,
+		"max_ai_requests": q.MaxAIRequests,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	s.record(org, actor, "quotas_updated", "organization", strconv.FormatUint(uint64(org.ID), 10), "Organization quotas updated", map[string]interface{}{
+		"max_members":     q.MaxMembers,
+		"max_projects":    q.MaxProjects,
+		"max_storage_gb":  q.MaxStorageGB,
+		"max_ai_requests": q.MaxAIRequests,
+	})
+	return &q, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupManagementTest(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &enterprise.Organization{}, &enterprise.AuditLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	service := NewService(db, enterprise.NewRBACService(db), enterprise.NewAuditService(db))
+	if err := service.AutoMigrate(); err != nil {
+		t.Fatalf("migrate management: %v", err)
+	}
+	return service, db
+}
+
+func createUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x", IsActive: true}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestApplyIsIdempotentAcrossResources(t *testing.T) {
+	service, db := setupManagementTest(t)
+	ada := createUser(t, db, "ada")
+	grace := createUser(t, db, "grace")
+	owner := Actor{UserID: ada.ID}
+	spec := OrganizationSpec{Name: "Acme", Description: "Widgets"}
+
+	org, created, err := service.ApplyOrganization(owner, "acme", spec)
+	if err != nil || !created || org.ExternalID != "acme" {
+		t.Fatalf("create org = %+v %v %v", org, created, err)
+	}
+	if _, created, err := service.ApplyOrganization(owner, "acme", spec); err != nil || created {
+		t.Fatalf("reapply org created=%v err=%v", created, err)
+	}
+	spec.Website = "https://acme.example"
+	if org, _, err := service.ApplyOrganization(owner, "acme", spec); err != nil || org.Website != spec.Website {
+		t.Fatalf("update org = %+v %v", org, err)
+	}
+	if _, _, err := service.ApplyOrganization(Actor{UserID: grace.ID}, "acme", spec); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("non-member update err = %v", err)
+	}
+
+	roleSpec := RoleSpec{Description: "Builds things", Permissions: []string{"projects:create", "Projects:Read", "projects:read"}}
+	role, created, err := service.ApplyRole(owner, "acme", "developer", roleSpec)
+	if err != nil || !created || len(role.Permissions) != 2 || role.Permissions[0] != "projects:create" {
+		t.Fatalf("create role = %+v %v %v", role, created, err)
+	}
+	if _, created, err := service.ApplyRole(owner, "acme", "developer", roleSpec); err != nil || created {
+		t.Fatalf("reapply role created=%v err=%v", created, err)
+	}
+	if _, _, err := service.ApplyRole(owner, "acme", "owner", roleSpec); !errors.Is(err, ErrReservedRole) {
+		t.Fatalf("owner role err = %v", err)
+	}
+	if _, _, err := service.ApplyRole(owner, "acme", "ops", RoleSpec{Permissions: []string{"servers:reboot"}}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("unknown permission err = %v", err)
+	}
+
+	member, created, err := service.ApplyMember(owner, "acme", "GRACE@example.com", MemberSpec{Role: "developer"})
+	if err != nil || !created || member.ExternalID != "grace@example.com" || member.Role != "developer" {
+		t.Fatalf("add member = %+v %v %v", member, created, err)
+	}
+	if _, created, err := service.ApplyMember(owner, "acme", "grace@example.com", MemberSpec{Role: "developer"}); err != nil || created {
+		t.Fatalf("reapply member created=%v err=%v", created, err)
+	}
+	if _, _, err := service.ApplyMember(owner, "acme", "nobody@example.com", MemberSpec{Role: "developer"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unknown user err = %v", err)
+	}
+	if !service.rbac.HasPermission(org.ID, grace.ID, "projects", "read") {
+		t.Fatal("member did not get the role's permissions")
+	}
+
+	// The only manager cannot be removed or demoted
+	if err := service.DeleteMember(owner, "acme", "ada@example.com"); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("remove last owner err = %v", err)
+	}
+	if _, _, err := service.ApplyMember(owner, "acme", "ada@example.com", MemberSpec{Role: "developer"}); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("demote last owner err = %v", err)
+	}
+	if err := service.DeleteRole(owner, "acme", "developer"); !errors.Is(err, ErrRoleInUse) {
+		t.Fatalf("delete held role err = %v", err)
+	}
+	if err := service.DeleteMember(owner, "acme", "grace@example.com"); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	if err := service.DeleteRole(owner, "acme", "developer"); err != nil {
+		t.Fatalf("delete role: %v", err)
+	}
+	if _, err := service.GetRole(owner, "acme", "developer"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted role err = %v", err)
+	}
+
+	var actions []string
+	db.Model(&enterprise.AuditLog{}).Order("id").Pluck("action", &actions)
+	want := []string{"organization_created", "organization_updated", "role_created", "member_added", "member_removed", "role_deleted"}
+	if len(actions) != len(want) {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("audit actions = %v, want %v", actions, want)
+		}
+	}
+}
+
+func TestQuotasRespectPlanCeiling(t *testing.T) {
+	service, db := setupManagementTest(t)
+	ada := createUser(t, db, "ada")
+	owner := Actor{UserID: ada.ID}
+	if _, _, err := service.ApplyOrganization(owner, "acme", OrganizationSpec{Name: "Acme"}); err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+
+	lower := Quotas{MaxMembers: 10, MaxProjects: 50, MaxStorageGB: 20, MaxAIRequests: 5000}
+	if got, err := service.ApplyQuotas(owner, "acme", lower); err != nil || *got != lower {
+		t.Fatalf("lower quotas = %+v %v", got, err)
+	}
+	higher := Quotas{MaxMembers: 500, MaxProjects: 50, MaxStorageGB: 20, MaxAIRequests: 5000}
+	if _, err := service.ApplyQuotas(owner, "acme", higher); !errors.Is(err, ErrQuotaAboveCeiling) {
+		t.Fatalf("raise quotas err = %v", err)
+	}
+	if got, err := service.ApplyQuotas(Actor{UserID: ada.ID, Admin: true}, "acme", higher); err != nil || got.MaxMembers != 500 {
+		t.Fatalf("admin raise = %+v %v", got, err)
+	}
+	if got, _ := service.GetQuotas(owner, "acme"); got.MaxMembers != 500 {
+		t.Fatalf("stored quotas = %+v", got)
+	}
+}
+
+func TestTokensAuthenticateUntilRevoked(t *testing.T) {
+	service, db := setupManagementTest(t)
+	ada := createUser(t, db, "ada")
+
+	token, plaintext, err := service.CreateToken(ada.ID, "terraform", time.Hour)
+	if err != nil || token.ExpiresAt == nil || len(plaintext) != len(TokenPrefix)+48 {
+		t.Fatalf("create token = %+v %q %v", token, plaintext, err)
+	}
+	user, _, err := service.Authenticate(plaintext)
+	if err != nil || user.ID != ada.ID {
+		t.Fatalf("authenticate = %+v %v", user, err)
+	}
+	if _, _, err := service.Authenticate(plaintext + "x"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("wrong token err = %v", err)
+	}
+	if err := service.RevokeToken(ada.ID, token.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, _, err := service.Authenticate(plaintext); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("revoked token err = %v", err)
+	}
+
+	_, expired, _ := service.CreateToken(ada.ID, "old", time.Hour)
+	service.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, _, err := service.Authenticate(expired); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired token err = %v", err)
+	}
+}
+
+func TestWebhooksReceiveSignedAuditEvents(t *testing.T) {
+	service, db := setupManagementTest(t)
+	ada := createUser(t, db, "ada")
+	owner := Actor{UserID: ada.ID}
+	if _, _, err := service.ApplyOrganization(owner, "acme", OrganizationSpec{Name: "Acme"}); err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+
+	if _, _, err := service.ApplyWebhook(owner, "acme", "ci", WebhookSpec{URL: "http://hooks.example.com", Secret: "0123456789abcdef"}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("http url err = %v", err)
+	}
+	if _, _, err := service.ApplyWebhook(owner, "acme", "ci", WebhookSpec{URL: "https://10.0.0.5/hook", Secret: "0123456789abcdef"}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("internal url err = %v", err)
+	}
+	if _, _, err := service.ApplyWebhook(owner, "acme", "ci", WebhookSpec{URL: "https://hooks.example.com"}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("missing secret err = %v", err)
+	}
+	spec := WebhookSpec{URL: "https://hooks.example.com/apex", Events: []string{"member_added"}, Secret: "0123456789abcdef"}
+	hook, created, err := service.ApplyWebhook(owner, "acme", "ci", spec)
+	if err != nil || !created || !hook.Enabled {
+		t.Fatalf("create webhook = %+v %v %v", hook, created, err)
+	}
+	spec.Secret = ""
+	if _, created, err := service.ApplyWebhook(owner, "acme", "ci", spec); err != nil || created {
+		t.Fatalf("reapply webhook created=%v err=%v", created, err)
+	}
+
+	received := make(chan *http.Request, 1)
+	var body []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer server.Close()
+	service.client = server.Client()
+	db.Model(&Webhook{}).Where("external_id = ?", "ci").Update("url", server.URL)
+
+	org, _ := service.organization("acme")
+	service.deliver(WebhookEvent{Event: "role_created", OrganizationID: org.ID})
+	service.deliver(WebhookEvent{ID: "42", Event: "member_added", OrganizationID: org.ID})
+
+	select {
+	case r := <-received:
+		if r.Header.Get(HeaderEvent) != "member_added" {
+			t.Fatalf("event header = %q", r.Header.Get(HeaderEvent))
+		}
+		if want := Sign("0123456789abcdef", r.Header.Get(HeaderTimestamp), body); r.Header.Get(HeaderSignature) != want {
+			t.Fatalf("signature = %q, want %q", r.Header.Get(HeaderSignature), want)
+		}
+		var event WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.ID != "42" {
+			t.Fatalf("payload = %s %v", body, err)
+		}
+	default:
+		t.Fatal("subscribed event was not delivered")
+	}
+	if len(received) != 0 {
+		t.Fatal("unsubscribed event was delivered")
+	}
+	stored, _ := service.webhook(org.ID, "ci")
+	if stored.LastStatus != http.StatusOK || stored.LastDeliveryAt == nil {
+		t.Fatalf("delivery status = %+v", stored)
+	}
+}
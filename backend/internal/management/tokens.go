@@ -0,0 +1,136 @@
+package management
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenTouchInterval limits last_used_at writes for busy tokens
+const tokenTouchInterval = time.Minute
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken issues a management token for the user. The plaintext token
+// is returned once and cannot be recovered.
+func (s *Service) CreateToken(userID uint, name string, ttl time.Duration) (*Token, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, "", fmt.Errorf("%w: name is required (max 100 characters)", ErrInvalid)
+	}
+	var active int64
+	if err := s.db.Model(&Token{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&active).Error; err != nil {
+		return nil, "", err
+	}
+	if active >= MaxTokensPerUser {
+		return nil, "", ErrTokenLimit
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	plaintext := TokenPrefix + hex.EncodeToString(raw)
+	token := &Token{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plaintext[:len(TokenPrefix)+6],
+		TokenHash: hashToken(plaintext),
+	}
+	if ttl > 0 {
+		expires := s.now().Add(ttl)
+		token.ExpiresAt = &expires
+	}
+	if err := s.db.Create(token).Error; err != nil {
+		return nil, "", err
+	}
+	return token, plaintext, nil
+}
+
+// ListTokens returns the user's tokens, newest first
+func (s *Service) ListTokens(userID uint) ([]Token, error) {
+	var tokens []Token
+	err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeToken revokes one of the user's tokens
+func (s *Service) RevokeToken(userID, tokenID uint) error {
+	now := s.now()
+	result := s.db.Model(&Token{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves a plaintext token to its active user
+func (s *Service) Authenticate(plaintext string) (*models.User, *Token, error) {
+	if !strings.HasPrefix(plaintext, TokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+	var token Token
+	if err := s.db.Where("token_hash = ?", hashToken(plaintext)).Take(&token).Error; err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	now := s.now()
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && now.After(*token.ExpiresAt)) {
+		return nil, nil, ErrInvalidToken
+	}
+	var user models.User
+	if err := s.db.Select("id", "username", "email", "is_active", "is_admin", "is_super_admin").
+		First(&user, token.UserID).Error; err != nil || !user.IsActive {
+		return nil, nil, ErrInvalidToken
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > tokenTouchInterval {
+		s.db.Model(&Token{}).Where("id = ?", token.ID).Update("last_used_at", now)
+		token.LastUsedAt = &now
+	}
+	return &user, &token, nil
+}
+
+// Middleware authenticates management API requests with a bearer
+// management token and sets the same user context keys as session auth
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		plaintext := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if header == "" || plaintext == header {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "A management token is required (Authorization: Bearer " + TokenPrefix + "...)",
+				"code":  "AUTH_REQUIRED",
+			})
+			return
+		}
+		user, token, err := s.Authenticate(plaintext)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+				"code":  "INVALID_TOKEN",
+			})
+			return
+		}
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("email", user.Email)
+		c.Set("is_admin", user.IsAdmin)
+		c.Set("is_super_admin", user.IsSuperAdmin)
+		c.Set("management_token_id", token.ID)
+		c.Next()
+	}
+}
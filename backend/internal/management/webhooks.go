@@ -0,0 +1,330 @@
+package management
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/netguard"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 2
+	// minWebhookSecret is the shortest signing secret accepted
+	minWebhookSecret = 16
+)
+
+// Webhook delivery headers
+const (
+	HeaderEvent     = "X-Apex-Event"
+	HeaderDelivery  = "X-Apex-Delivery"
+	HeaderTimestamp = "X-Apex-Timestamp"
+	// HeaderSignature is "sha256=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" under the webhook secret
+	HeaderSignature = "X-Apex-Signature"
+)
+
+var eventPattern = regexp.MustCompile(`^(\*|[a-z][a-z0-9_]{0,63})$`)
+
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: netguard.DenyInternal("webhooks")}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || len(raw) > 500 {
+		return fmt.Errorf("%w: url must be an https URL (max 500 characters)", ErrInvalid)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return fmt.Errorf("%w: url may not point at an internal host", ErrInvalid)
+	}
+	if ip := net.ParseIP(host); ip != nil && netguard.IsInternal(ip) {
+		return fmt.Errorf("%w: url may not point at an internal address", ErrInvalid)
+	}
+	return nil
+}
+
+func normalizeEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return []string{"*"}, nil
+	}
+	if len(events) > MaxWebhookEvents {
+		return nil, fmt.Errorf("%w: at most %d events", ErrInvalid, MaxWebhookEvents)
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(events))
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !eventPattern.MatchString(event) {
+			return nil, fmt.Errorf("%w: invalid event %q", ErrInvalid, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			out = append(out, event)
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) webhook(orgID uint, externalID string) (*Webhook, error) {
+	var hook Webhook
+	err := s.db.Where("organization_id = ? AND external_id = ?", orgID, externalID).First(&hook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns the organization's webhooks
+func (s *Service) ListWebhooks(actor Actor, slug string) ([]Webhook, error) {
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return nil, err
+	}
+	var hooks []Webhook
+	err = s.db.Where("organization_id = ?", org.ID).Order("external_id").Find(&hooks).Error
+	return hooks, err
+}
+
+// GetWebhook returns a webhook by external ID
+func (s *Service) GetWebhook(actor Actor, slug, externalID string) (*Webhook, error) {
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return nil, err
+	}
+	return s.webhook(org.ID, externalID)
+}
+
+// ApplyWebhook creates or updates a webhook. It reports whether the webhook
+// was created.
+func (s *Service) ApplyWebhook(actor Actor, slug, externalID string, spec WebhookSpec) (*Webhook, bool, error) {
+	if !externalIDPattern.MatchString(externalID) {
+		return nil, false, fmt.Errorf("%w: webhook IDs are 1-64 lowercase letters, digits, dots, underscores or hyphens", ErrInvalid)
+	}
+	spec.URL = strings.TrimSpace(spec.URL)
+	if err := validateWebhookURL(spec.URL); err != nil {
+		return nil, false, err
+	}
+	events, err := normalizeEvents(spec.Events)
+	if err != nil {
+		return nil, false, err
+	}
+	if spec.Secret != "" && (len(spec.Secret) < minWebhookSecret || len(spec.Secret) > 200) {
+		return nil, false, fmt.Errorf("%w: secret must be %d-200 characters", ErrInvalid, minWebhookSecret)
+	}
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return nil, false, err
+	}
+
+	hook, err := s.webhook(org.ID, externalID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+	created := hook == nil
+	if created {
+		if spec.Secret == "" {
+			return nil, false, fmt.Errorf("%w: secret is required for a new webhook", ErrInvalid)
+		}
+		var count int64
+		s.db.Model(&Webhook{}).Where("organization_id = ?", org.ID).Count(&count)
+		if count >= MaxWebhooksPerOrganization {
+			return nil, false, fmt.Errorf("%w: at most %d webhooks per organization", ErrInvalid, MaxWebhooksPerOrganization)
+		}
+		hook = &Webhook{OrganizationID: org.ID, ExternalID: externalID, Enabled: true}
+	}
+	enabled := hook.Enabled
+	if spec.Enabled != nil {
+		enabled = *spec.Enabled
+	}
+	secretChanged := spec.Secret != "" && spec.Secret != hook.Secret
+	if !created && hook.URL == spec.URL && strings.Join(hook.Events, ",") == strings.Join(events, ",") &&
+		hook.Enabled == enabled && !secretChanged {
+		return hook, false, nil
+	}
+	hook.URL = spec.URL
+	hook.Events = events
+	hook.Enabled = enabled
+	if spec.Secret != "" {
+		hook.Secret = spec.Secret
+	}
+	if err := s.db.Save(hook).Error; err != nil {
+		return nil, false, err
+	}
+	action := "webhook_updated"
+	if created {
+		action = "webhook_created"
+	}
+	s.record(org, actor, action, "webhook", externalID, "Webhook "+externalID+" saved", map[string]interface{}{
+		"url": hook.URL, "events": events, "enabled": enabled, "secret_rotated": secretChanged && !created,
+	})
+	return hook, created, nil
+}
+
+// DeleteWebhook deletes a webhook
+func (s *Service) DeleteWebhook(actor Actor, slug, externalID string) error {
+	org, err := s.authorize(actor, slug, "organization", "manage")
+	if err != nil {
+		return err
+	}
+	hook, err := s.webhook(org.ID, externalID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(hook).Error; err != nil {
+		return err
+	}
+	s.record(org, actor, "webhook_deleted", "webhook", externalID, "Webhook "+externalID+" deleted", nil)
+	return nil
+}
+
+// WebhookEvent is the JSON body of a webhook delivery
+type WebhookEvent struct {
+	ID             string                 `json:"id"`
+	Event          string                 `json:"event"`
+	OrganizationID uint                   `json:"organization_id"`
+	ActorID        *uint                  `json:"actor_id,omitempty"`
+	ResourceType   string                 `json:"resource_type,omitempty"`
+	ResourceID     string                 `json:"resource_id,omitempty"`
+	Description    string                 `json:"description,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+	OccurredAt     time.Time              `json:"occurred_at"`
+}
+
+// DeliverAuditEvent sends an organization audit event to its subscribed
+// webhooks in the background. Register it as an audit listener.
+func (s *Service) DeliverAuditEvent(entry *enterprise.AuditLog) {
+	if entry == nil || entry.OrganizationID == nil {
+		return
+	}
+	event := WebhookEvent{
+		ID:             strconv.FormatUint(uint64(entry.ID), 10),
+		Event:          entry.Action,
+		OrganizationID: *entry.OrganizationID,
+		ActorID:        entry.UserID,
+		ResourceType:   entry.ResourceType,
+		ResourceID:     entry.ResourceID,
+		Description:    entry.Description,
+		Data:           entry.NewValue,
+		OccurredAt:     entry.CreatedAt.UTC(),
+	}
+	go s.deliver(event)
+}
+
+func (s *Service) deliver(event WebhookEvent) {
+	var hooks []Webhook
+	if err := s.db.Where("organization_id = ? AND enabled = ?", event.OrganizationID, true).Find(&hooks).Error; err != nil {
+		log.Printf("[management] load webhooks for organization %d: %v", event.OrganizationID, err)
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for i := range hooks {
+		hook := &hooks[i]
+		if !containsString(hook.Events, "*") && !containsString(hook.Events, event.Event) {
+			continue
+		}
+		status, err := s.send(hook, event.Event, body)
+		now := s.now()
+		update := map[string]interface{}{"last_delivery_at": now, "last_status": status, "last_error": ""}
+		if err != nil {
+			update["last_error"] = truncate(err.Error(), 500)
+			log.Printf("[management] webhook %s for organization %d failed: %v", hook.ExternalID, hook.OrganizationID, err)
+		}
+		s.db.Model(&Webhook{}).Where("id = ?", hook.ID).Updates(update)
+	}
+}
+
+// Sign returns the signature header value for a delivery
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) send(hook *Webhook, event string, body []byte) (int, error) {
+	delivery := uuid.NewString()
+	var status int
+	var lastErr error
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Second)
+		}
+		timestamp := strconv.FormatInt(s.now().Unix(), 10)
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+		if err != nil {
+			cancel()
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "apex-build-webhooks")
+		req.Header.Set(HeaderEvent, event)
+		req.Header.Set(HeaderDelivery, delivery)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+		resp, err := s.client.Do(req)
+		if err != nil {
+			cancel()
+			lastErr = err
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		cancel()
+		status = resp.StatusCode
+		if status >= 200 && status < 300 {
+			return status, nil
+		}
+		lastErr = fmt.Errorf("endpoint returned %d", status)
+		if status < 500 && status != http.StatusTooManyRequests {
+			break
+		}
+	}
+	return status, lastErr
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
@@ -52,6 +52,9 @@ type Metrics struct {
 	WebSocketMessagesTotal    *prometheus.CounterVec
 	WebSocketMessageSize      *prometheus.HistogramVec
 	WebSocketLatency          *prometheus.HistogramVec
+	WebSocketDroppedTotal     *prometheus.CounterVec
+	WebSocketQueuedMessages   *prometheus.GaugeVec
+	WebSocketSlowClientsTotal prometheus.Counter
 
 	// Database Metrics
 	DBConnectionsActive prometheus.Gauge
@@ -353,6 +356,35 @@ func newMetrics() *Metrics {
 		[]string{"type"},
 	)
 
+	m.WebSocketDroppedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "apex",
+			Subsystem: "websocket",
+			Name:      "dropped_messages_total",
+			Help:      "Total number of outbound WebSocket messages dropped from client send queues",
+		},
+		[]string{"type", "reason"},
+	)
+
+	m.WebSocketQueuedMessages = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "apex",
+			Subsystem: "websocket",
+			Name:      "queued_messages",
+			Help:      "Current number of outbound WebSocket messages waiting in client send queues by hub",
+		},
+		[]string{"hub"},
+	)
+
+	m.WebSocketSlowClientsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "apex",
+			Subsystem: "websocket",
+			Name:      "slow_clients_disconnected_total",
+			Help:      "Total number of WebSocket clients disconnected for not keeping up",
+		},
+	)
+
 	// Database Metrics
 	m.DBConnectionsActive = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -532,6 +564,21 @@ func (m *Metrics) RecordWebSocketMessage(msgType, direction string, size int) {
 	m.WebSocketMessageSize.WithLabelValues(msgType).Observe(float64(size))
 }
 
+// RecordWebSocketDrop records an outbound message dropped from a client queue
+func (m *Metrics) RecordWebSocketDrop(msgType, reason string) {
+	m.WebSocketDroppedTotal.WithLabelValues(msgType, reason).Inc()
+}
+
+// SetWebSocketQueuedMessages sets the number of messages waiting in a hub's client queues
+func (m *Metrics) SetWebSocketQueuedMessages(hub string, count int) {
+	m.WebSocketQueuedMessages.WithLabelValues(hub).Set(float64(count))
+}
+
+// RecordWebSocketSlowClient records a slow client disconnect
+func (m *Metrics) RecordWebSocketSlowClient() {
+	m.WebSocketSlowClientsTotal.Inc()
+}
+
 // RecordCacheOperation records a cache hit or miss
 func (m *Metrics) RecordCacheOperation(cacheName string, hit bool) {
 	if hit {
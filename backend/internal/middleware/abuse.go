@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apex-build/internal/abuse"
+
+	"github.com/gin-gonic/gin"
+)
+
+// abuseUngatedSuffixes are POST routes that stop or adjust work already
+// running, which a suspended account must still be able to do.
+var abuseUngatedSuffixes = []string{"/stop", "/interrupt", "/resize"}
+
+// AbuseGate returns a middleware that refuses new executions for accounts
+// that are suspended or over their throttled run allowance, and for
+// disposable email addresses. Only POST requests are gated so users can
+// still read results and close sessions. Throttled accounts pass with
+// "abuse_max_timeout" set for the execution handlers.
+func AbuseGate(service *abuse.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if service == nil || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		path := c.FullPath()
+		for _, suffix := range abuseUngatedSuffixes {
+			if strings.HasSuffix(path, suffix) {
+				c.Next()
+				return
+			}
+		}
+
+		userID := c.GetUint("user_id")
+		if userID == 0 {
+			c.Next()
+			return
+		}
+
+		decision := service.Admit(c.Request.Context(), userID)
+		if !decision.Allowed {
+			status := http.StatusForbidden
+			if decision.Code == abuse.CodeThrottled {
+				status = http.StatusTooManyRequests
+				c.Header("Retry-After", strconv.Itoa(int(decision.RetryAfter.Seconds())))
+			}
+			body := gin.H{
+				"success": false,
+				"error":   decision.Reason,
+				"code":    decision.Code,
+			}
+			if decision.CaseID != 0 {
+				body["case_id"] = decision.CaseID
+				body["appeal_url"] = "/api/v1/abuse/appeal"
+			}
+			c.AbortWithStatusJSON(status, body)
+			return
+		}
+		if decision.MaxTimeout > 0 {
+			c.Set("abuse_max_timeout", decision.MaxTimeout)
+		}
+		c.Next()
+	}
+}
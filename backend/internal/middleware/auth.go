@@ -63,6 +63,8 @@ func RequireAuth(authService *auth.AuthService) gin.HandlerFunc {
 		c.Set("bypass_rate_limits", claims.BypassRateLimits)
 		c.Set("token_claims", claims)
 		c.Set("raw_token", token) // Store raw token for logout blacklisting
+		c.Set("session_id", claims.SessionID)
+		authService.TouchSession(claims.SessionID, c.ClientIP())
 
 		c.Next()
 	}
@@ -176,6 +178,7 @@ func OptionalAuth(authService *auth.AuthService) gin.HandlerFunc {
 		c.Set("bypass_billing", claims.BypassBilling)
 		c.Set("bypass_rate_limits", claims.BypassRateLimits)
 		c.Set("token_claims", claims)
+		c.Set("session_id", claims.SessionID)
 		c.Set("authenticated", true)
 
 		c.Next()
@@ -210,6 +213,12 @@ func GetUserID(c *gin.Context) (uint, bool) {
 	return id, true
 }
 
+// GetSessionID returns the caller's session, empty for tokens issued
+// without one
+func GetSessionID(c *gin.Context) string {
+	return c.GetString("session_id")
+}
+
 // RequireUserID extracts a validated user ID from request context or aborts with 401.
 func RequireUserID(c *gin.Context) (uint, bool) {
 	id, ok := GetUserID(c)
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StrongETag builds a quoted strong entity tag from the given parts.
+func StrongETag(parts ...string) string {
+	return `"` + etagHash(parts) + `"`
+}
+
+// WeakETag builds a weak entity tag, for representations that are
+// semantically but not byte-for-byte equivalent (e.g. cached vs fresh).
+func WeakETag(parts ...string) string {
+	return `W/"` + etagHash(parts) + `"`
+}
+
+func etagHash(parts []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ETagMatches reports whether an If-Match / If-None-Match header value
+// matches etag. It accepts "*" and comma-separated lists and compares
+// weakly, ignoring W/ prefixes.
+func ETagMatches(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified sets the ETag header and, when the request's If-None-Match
+// matches it, answers 304 Not Modified. Callers return when it reports true.
+func NotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+	return false
+}
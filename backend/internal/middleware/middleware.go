@@ -363,8 +363,8 @@ func CORS() gin.HandlerFunc {
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, X-Request-ID, X-Apex-Operation-ID, X-Apex-Client-Trace-ID")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Apex-Operation-ID")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, X-Request-ID, X-Apex-Operation-ID, X-Apex-Client-Trace-ID, If-Match, If-None-Match, Range, Upload-Offset")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Apex-Operation-ID, ETag, Location, Content-Range, Accept-Ranges, Upload-Offset, Upload-Length, Upload-Expires")
 		c.Header("Access-Control-Max-Age", "86400") // 24 hours
 
 		// Handle preflight OPTIONS requests
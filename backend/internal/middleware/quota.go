@@ -5,6 +5,8 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -127,6 +129,73 @@ func (q *QuotaChecker) CheckStorageQuota(estimatedBytes int64) gin.HandlerFunc {
 	}
 }
 
+// AllowStorageDelta checks that growing the user's storage by bytes stays
+// within plan limits. Handlers call it once the real payload size is known;
+// it writes a 429 (or 503) and returns false when the write must be refused.
+func (q *QuotaChecker) AllowStorageDelta(c *gin.Context, bytes int64) bool {
+	userID, ok := GetUserID(c)
+	if !ok || bytes <= 0 || q.bypassesBilling(c) {
+		return true
+	}
+	plan := q.getUserPlan(c)
+
+	allowed, current, limit, err := q.tracker.CheckQuota(c.Request.Context(), userID, plan, usage.UsageStorageBytes, bytes)
+	if err != nil {
+		q.sendQuotaUnavailable(c, usage.UsageStorageBytes)
+		return false
+	}
+	if !allowed {
+		response := quotaExceededResponse(c, usage.UsageStorageBytes, current, limit, plan)
+		response.Details["requested"] = bytes
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, response)
+		return false
+	}
+	return true
+}
+
+// AllowProjectStorageDelta is AllowStorageDelta for a write to a project:
+// organization projects are checked against the organization's storage
+// limit, which members cannot bypass with their own plan.
+func (q *QuotaChecker) AllowProjectStorageDelta(c *gin.Context, projectID uint, bytes int64) bool {
+	userID, ok := GetUserID(c)
+	if !ok || bytes <= 0 {
+		return true
+	}
+	if q.bypassesBilling(c) {
+		return true
+	}
+	plan := q.getUserPlan(c)
+
+	allowed, current, limit, orgID, err := q.tracker.CheckProjectStorageQuota(c.Request.Context(), userID, plan, projectID, bytes)
+	if err != nil {
+		q.sendQuotaUnavailable(c, usage.UsageStorageBytes)
+		return false
+	}
+	if !allowed {
+		response := quotaExceededResponse(c, usage.UsageStorageBytes, current, limit, plan)
+		response.Details["requested"] = bytes
+		if orgID != nil {
+			response.Details["organization_id"] = *orgID
+			response.Error = "Organization storage limit reached"
+			response.UpgradeMsg = "Ask an organization admin to raise the storage limit or free up space"
+			response.NextPlan = ""
+			response.NextLimit = 0
+		}
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, response)
+		return false
+	}
+	return true
+}
+
+// MaxFileBytes returns the largest single file the user's plan may upload.
+// Users who bypass billing get the top tier's limit.
+func (q *QuotaChecker) MaxFileBytes(c *gin.Context) int64 {
+	if q.bypassesBilling(c) {
+		return usage.GetPlanLimits(usage.PlanOwner).MaxFileBytes
+	}
+	return usage.GetPlanLimits(q.getUserPlan(c)).MaxFileBytes
+}
+
 // CheckAIQuota middleware checks if user has AI request quota
 func (q *QuotaChecker) CheckAIQuota() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -207,6 +276,47 @@ func (q *QuotaChecker) CheckExecutionQuota(estimatedMinutes int64) gin.HandlerFu
 	}
 }
 
+// ReserveExecution holds execution minutes for a run of up to timeout before
+// the sandbox starts. It writes a 429 (or 503) and returns false when the
+// reservation is refused; otherwise it returns the reservation ID to settle
+// once the run finishes. CheckExecutionQuota only pre-checks a single minute,
+// so handlers must reserve once they know the requested timeout.
+func (q *QuotaChecker) ReserveExecution(c *gin.Context, timeout time.Duration) (string, bool) {
+	userID, ok := GetUserID(c)
+	if !ok {
+		return "", true
+	}
+	plan := q.getUserPlan(c)
+
+	reservation, err := q.tracker.ReserveExecution(c.Request.Context(), userID, plan, timeout, !q.bypassesBilling(c))
+	if err != nil {
+		var quotaErr *usage.ExecutionQuotaError
+		if errors.As(err, &quotaErr) {
+			response := quotaExceededResponse(c, usage.UsageExecutionMinutes, quotaErr.Used+quotaErr.Reserved, quotaErr.Limit, plan)
+			response.Details["used"] = quotaErr.Used
+			response.Details["reserved"] = quotaErr.Reserved
+			response.Details["requested"] = quotaErr.Requested
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, response)
+			return "", false
+		}
+		q.sendQuotaUnavailable(c, usage.UsageExecutionMinutes)
+		return "", false
+	}
+	return reservation.ID, true
+}
+
+// SettleExecution charges the measured wall/CPU time against a reservation
+// and returns the unused minutes to the budget.
+func (q *QuotaChecker) SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) error {
+	_, err := q.tracker.SettleExecution(ctx, reservationID, projectID, durationMs, cpuTimeMs)
+	return err
+}
+
+// ReleaseExecution returns a reservation for a run that never completed.
+func (q *QuotaChecker) ReleaseExecution(ctx context.Context, reservationID string) error {
+	return q.tracker.ReleaseExecution(ctx, reservationID)
+}
+
 // GenericQuotaCheck is a generic quota check that can be used for any usage type
 func (q *QuotaChecker) GenericQuotaCheck(usageType usage.UsageType, amount int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -301,6 +411,11 @@ func (q *QuotaChecker) bypassesBilling(c *gin.Context) bool {
 
 // sendQuotaExceeded sends a 429 response with upgrade information
 func (q *QuotaChecker) sendQuotaExceeded(c *gin.Context, usageType usage.UsageType, current, limit int64, plan usage.PlanType) {
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, quotaExceededResponse(c, usageType, current, limit, plan))
+}
+
+// quotaExceededResponse builds the 429 body shared by the quota checks
+func quotaExceededResponse(c *gin.Context, usageType usage.UsageType, current, limit int64, plan usage.PlanType) QuotaExceededResponse {
 	requestID := c.GetHeader("X-Request-ID")
 	if requestID == "" {
 		if rid, exists := c.Get("request_id"); exists {
@@ -350,7 +465,7 @@ func (q *QuotaChecker) sendQuotaExceeded(c *gin.Context, usageType usage.UsageTy
 	response.Details["current_formatted"] = formatUsageValue(usageType, current)
 	response.Details["limit_formatted"] = formatUsageValue(usageType, limit)
 
-	c.AbortWithStatusJSON(http.StatusTooManyRequests, response)
+	return response
 }
 
 func (q *QuotaChecker) sendQuotaUnavailable(c *gin.Context, usageType usage.UsageType) {
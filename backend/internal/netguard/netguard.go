@@ -0,0 +1,33 @@
+// Package netguard keeps requests to user-supplied URLs away from the
+// platform's internal network.
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// IsInternal reports whether ip is a loopback, private, link-local,
+// unspecified or multicast address
+func IsInternal(ip net.IP) bool {
+	return ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// DenyInternal returns a net.Dialer Control function that refuses internal
+// addresses. It runs after DNS resolution and on every redirect, so a
+// public name pointing at an internal address is refused too. subject
+// names what is dialing in the error, such as "webhooks".
+func DenyInternal(subject string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if IsInternal(net.ParseIP(host)) {
+			return fmt.Errorf("%s may not point at internal addresses", subject)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,21 @@
+package netguard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDenyInternalRefusesInternalAddresses(t *testing.T) {
+	control := DenyInternal("webhooks")
+	for _, address := range []string{"127.0.0.1:80", "10.1.2.3:443", "169.254.169.254:80", "[::1]:80", "0.0.0.0:80", "[fd00::1]:443"} {
+		err := control("tcp", address, nil)
+		if err == nil || !strings.Contains(err.Error(), "webhooks may not point at internal addresses") {
+			t.Fatalf("DenyInternal(%s) = %v, want refusal", address, err)
+		}
+	}
+	for _, address := range []string{"93.184.216.34:443", "[2606:2800:220:1::]:443"} {
+		if err := control("tcp", address, nil); err != nil {
+			t.Fatalf("DenyInternal(%s) = %v, want allowed", address, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package notebook runs Jupyter-style notebooks: .ipynb files stored as
+// project files, and persistent language kernels inside the execution
+// sandbox that execute cells and stream rich output.
+package notebook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Notebook is an nbformat v4 document. Unknown metadata is preserved so
+// files round-trip through the editor without losing Jupyter fields.
+type Notebook struct {
+	Cells         []*Cell        `json:"cells"`
+	Metadata      map[string]any `json:"metadata"`
+	NBFormat      int            `json:"nbformat"`
+	NBFormatMinor int            `json:"nbformat_minor"`
+}
+
+// Cell is a single notebook cell. Source is normalized to one string on
+// parse and written back as a list of lines, as Jupyter does.
+type Cell struct {
+	ID             string         `json:"id,omitempty"`
+	CellType       string         `json:"cell_type"`
+	Source         MultilineText  `json:"source"`
+	Metadata       map[string]any `json:"metadata"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+	Outputs        []Output       `json:"outputs,omitempty"`
+}
+
+// Output is one cell output. Data holds a MIME bundle for execute_result and
+// display_data, for example image/png as base64 or application/json.
+type Output struct {
+	OutputType     string         `json:"output_type"`
+	Name           string         `json:"name,omitempty"`
+	Text           *MultilineText `json:"text,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+	EName          string         `json:"ename,omitempty"`
+	EValue         string         `json:"evalue,omitempty"`
+	Traceback      []string       `json:"traceback,omitempty"`
+}
+
+// MultilineText accepts nbformat's string-or-list-of-lines encoding.
+type MultilineText string
+
+// UnmarshalJSON joins list-form text into a single string.
+func (m *MultilineText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = MultilineText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return fmt.Errorf("notebook text must be a string or list of strings")
+	}
+	*m = MultilineText(strings.Join(lines, ""))
+	return nil
+}
+
+// MarshalJSON writes text as a list of lines that keep their newlines.
+func (m MultilineText) MarshalJSON() ([]byte, error) {
+	return json.Marshal(splitLines(string(m)))
+}
+
+func splitLines(s string) []string {
+	lines := []string{}
+	for s != "" {
+		idx := strings.IndexByte(s, '\n')
+		if idx < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:idx+1])
+		s = s[idx+1:]
+	}
+	return lines
+}
+
+// Cell types.
+const (
+	CellCode     = "code"
+	CellMarkdown = "markdown"
+	CellRaw      = "raw"
+)
+
+// New returns an empty notebook with kernelspec metadata for language.
+func New(language string) *Notebook {
+	language = normalizeLanguage(language)
+	return &Notebook{
+		Cells: []*Cell{},
+		Metadata: map[string]any{
+			"kernelspec": map[string]any{
+				"name":         language,
+				"display_name": kernelDisplayName(language),
+				"language":     language,
+			},
+			"language_info": map[string]any{"name": language},
+		},
+		NBFormat:      4,
+		NBFormatMinor: 5,
+	}
+}
+
+// Parse decodes and validates an .ipynb document.
+func Parse(content []byte) (*Notebook, error) {
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return New("python"), nil
+	}
+	var nb Notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	if nb.NBFormat != 4 {
+		return nil, fmt.Errorf("unsupported nbformat %d; only version 4 notebooks are supported", nb.NBFormat)
+	}
+	if nb.Metadata == nil {
+		nb.Metadata = map[string]any{}
+	}
+	if nb.Cells == nil {
+		nb.Cells = []*Cell{}
+	}
+	for i, cell := range nb.Cells {
+		if cell == nil {
+			return nil, fmt.Errorf("cell %d is empty", i)
+		}
+		switch cell.CellType {
+		case CellCode, CellMarkdown, CellRaw:
+		default:
+			return nil, fmt.Errorf("cell %d has unknown cell_type %q", i, cell.CellType)
+		}
+		if cell.Metadata == nil {
+			cell.Metadata = map[string]any{}
+		}
+		if cell.ID == "" {
+			cell.ID = fmt.Sprintf("cell-%d", i+1)
+		}
+	}
+	return &nb, nil
+}
+
+// Marshal encodes the notebook the way Jupyter writes it to disk.
+func Marshal(nb *Notebook) ([]byte, error) {
+	for _, cell := range nb.Cells {
+		// Code cells always carry outputs in nbformat, even when empty.
+		if cell.CellType == CellCode && cell.Outputs == nil {
+			cell.Outputs = []Output{}
+		}
+	}
+	data, err := json.MarshalIndent(nb, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Language reports the kernel language recorded in the notebook metadata.
+func (nb *Notebook) Language() string {
+	if spec, ok := nb.Metadata["kernelspec"].(map[string]any); ok {
+		if lang, ok := spec["language"].(string); ok && lang != "" {
+			return normalizeLanguage(lang)
+		}
+	}
+	if info, ok := nb.Metadata["language_info"].(map[string]any); ok {
+		if lang, ok := info["name"].(string); ok && lang != "" {
+			return normalizeLanguage(lang)
+		}
+	}
+	return "python"
+}
+
+// Cell returns the cell with id, or nil.
+func (nb *Notebook) Cell(id string) *Cell {
+	for _, cell := range nb.Cells {
+		if cell.ID == id {
+			return cell
+		}
+	}
+	return nil
+}
+
+func normalizeLanguage(language string) string {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "", "python", "python3", "py":
+		return "python"
+	default:
+		return strings.ToLower(strings.TrimSpace(language))
+	}
+}
+
+func kernelDisplayName(language string) string {
+	if language == "python" {
+		return "Python 3"
+	}
+	return language
+}
@@ -0,0 +1,477 @@
+package notebook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// messageMarker prefixes every framed message the kernel driver writes to
+// stdout. Lines without it are raw output from subprocesses or C extensions
+// and are attributed to the running cell as stdout.
+const messageMarker = "\x1eAPEX-NB "
+
+// maxMessageSize bounds a single framed message; a rendered figure or a
+// capped DataFrame fits comfortably.
+const maxMessageSize = 32 * 1024 * 1024
+
+// Kernel states.
+const (
+	StatusStarting = "starting"
+	StatusIdle     = "idle"
+	StatusBusy     = "busy"
+	StatusDead     = "dead"
+)
+
+// ErrKernelDead is returned when a cell is sent to a kernel whose process
+// has exited.
+var ErrKernelDead = errors.New("kernel is not running")
+
+func errUnsupportedLanguage(language string) error {
+	return fmt.Errorf("notebook kernels are not available for %q; supported: python", language)
+}
+
+// Process is a running kernel process. The execution sandbox provides the
+// production implementation; tests use in-memory pipes.
+type Process interface {
+	Streams() (stdin io.WriteCloser, stdout io.Reader, stderr io.Reader)
+	Interrupt() error
+	Kill() error
+}
+
+// Event is streamed to notebook WebSocket subscribers while cells run.
+type Event struct {
+	KernelID       string    `json:"kernel_id"`
+	ExecutionID    string    `json:"execution_id,omitempty"`
+	CellID         string    `json:"cell_id,omitempty"`
+	Type           string    `json:"type"` // status, output, execute_complete
+	Status         string    `json:"status,omitempty"`
+	Output         *Output   `json:"output,omitempty"`
+	ExecutionCount int       `json:"execution_count,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// ExecutionResult is the outcome of running one cell.
+type ExecutionResult struct {
+	ExecutionID    string   `json:"execution_id"`
+	CellID         string   `json:"cell_id,omitempty"`
+	Status         string   `json:"status"` // ok, error, aborted
+	ExecutionCount int      `json:"execution_count"`
+	Outputs        []Output `json:"outputs"`
+	DurationMs     int64    `json:"duration_ms"`
+}
+
+// KernelInfo is the JSON view of a kernel.
+type KernelInfo struct {
+	ID             string    `json:"id"`
+	ProjectID      uint      `json:"project_id"`
+	Language       string    `json:"language"`
+	Status         string    `json:"status"`
+	ExecutionCount int       `json:"execution_count"`
+	StartedAt      time.Time `json:"started_at"`
+	LastActivity   time.Time `json:"last_activity"`
+}
+
+// kernelMessage is one framed message from the driver.
+type kernelMessage struct {
+	ID             string         `json:"id"`
+	Type           string         `json:"type"`
+	Name           string         `json:"name"`
+	Text           string         `json:"text"`
+	Data           map[string]any `json:"data"`
+	ExecutionCount int            `json:"execution_count"`
+	EName          string         `json:"ename"`
+	EValue         string         `json:"evalue"`
+	Traceback      []string       `json:"traceback"`
+	Status         string         `json:"status"`
+}
+
+// kernelSession is one process generation; a restart replaces it.
+type kernelSession struct {
+	proc   Process
+	stdin  io.WriteCloser
+	ready  chan struct{}
+	exited chan struct{}
+}
+
+type pendingExecution struct {
+	result  *ExecutionResult
+	started time.Time
+	done    chan struct{}
+}
+
+// Kernel is a persistent interpreter that keeps state between cells. Cells
+// run one at a time in submission order.
+type Kernel struct {
+	ID        string
+	UserID    uint
+	ProjectID uint
+	Language  string
+
+	execMu sync.Mutex
+
+	mu             sync.Mutex
+	status         string
+	executionCount int
+	startedAt      time.Time
+	lastActivity   time.Time
+	session        *kernelSession
+	current        *pendingExecution
+	subscribers    map[int]chan Event
+	nextSubscriber int
+}
+
+func newKernel(userID, projectID uint, language string) *Kernel {
+	now := time.Now()
+	return &Kernel{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		ProjectID:    projectID,
+		Language:     language,
+		status:       StatusStarting,
+		startedAt:    now,
+		lastActivity: now,
+		subscribers:  make(map[int]chan Event),
+	}
+}
+
+// Info returns a snapshot of the kernel state.
+func (k *Kernel) Info() KernelInfo {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return KernelInfo{
+		ID:             k.ID,
+		ProjectID:      k.ProjectID,
+		Language:       k.Language,
+		Status:         k.status,
+		ExecutionCount: k.executionCount,
+		StartedAt:      k.startedAt,
+		LastActivity:   k.lastActivity,
+	}
+}
+
+// Subscribe returns a channel of kernel events and a function that ends the
+// subscription. Slow subscribers drop events rather than stall the kernel.
+func (k *Kernel) Subscribe() (<-chan Event, func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := k.nextSubscriber
+	k.nextSubscriber++
+	ch := make(chan Event, 256)
+	k.subscribers[id] = ch
+	return ch, func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if sub, ok := k.subscribers[id]; ok {
+			delete(k.subscribers, id)
+			close(sub)
+		}
+	}
+}
+
+// attach starts reading from a new kernel process.
+func (k *Kernel) attach(proc Process) *kernelSession {
+	stdin, stdout, stderr := proc.Streams()
+	sess := &kernelSession{
+		proc:   proc,
+		stdin:  stdin,
+		ready:  make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	k.mu.Lock()
+	k.session = sess
+	k.status = StatusStarting
+	k.executionCount = 0
+	k.startedAt = time.Now()
+	k.lastActivity = k.startedAt
+	k.mu.Unlock()
+
+	go k.readStderr(stderr)
+	go k.readStdout(sess, stdout)
+	return sess
+}
+
+// waitReady blocks until the driver reports it is ready.
+func (k *Kernel) waitReady(ctx context.Context, sess *kernelSession) error {
+	select {
+	case <-sess.ready:
+		return nil
+	case <-sess.exited:
+		return fmt.Errorf("kernel exited during startup")
+	case <-ctx.Done():
+		return fmt.Errorf("kernel did not start: %w", ctx.Err())
+	}
+}
+
+func (k *Kernel) readStdout(sess *kernelSession, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	var readyOnce sync.Once
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, messageMarker) {
+			k.appendOutput(Output{OutputType: "stream", Name: "stdout", Text: textPtr(line + "\n")})
+			continue
+		}
+		var msg kernelMessage
+		if err := json.Unmarshal([]byte(line[len(messageMarker):]), &msg); err != nil {
+			log.Printf("notebook kernel %s: malformed message: %v", k.ID, err)
+			continue
+		}
+		if msg.Type == "ready" {
+			readyOnce.Do(func() { close(sess.ready) })
+			k.setStatus(StatusIdle)
+			continue
+		}
+		k.handleMessage(msg)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("notebook kernel %s: read error: %v", k.ID, err)
+	}
+	close(sess.exited)
+
+	k.mu.Lock()
+	isCurrent := k.session == sess
+	k.mu.Unlock()
+	if isCurrent {
+		k.setStatus(StatusDead)
+		k.finishCurrent("aborted", 0)
+	}
+}
+
+func (k *Kernel) readStderr(stderr io.Reader) {
+	if stderr == nil {
+		return
+	}
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		k.appendOutput(Output{OutputType: "stream", Name: "stderr", Text: textPtr(scanner.Text() + "\n")})
+	}
+}
+
+func (k *Kernel) handleMessage(msg kernelMessage) {
+	// Late messages from an abandoned cell must not land on the next one.
+	k.mu.Lock()
+	stale := k.current == nil || (msg.ID != "" && msg.ID != k.current.result.ExecutionID)
+	k.mu.Unlock()
+	if stale {
+		return
+	}
+	switch msg.Type {
+	case "stream":
+		name := msg.Name
+		if name != "stderr" {
+			name = "stdout"
+		}
+		k.appendOutput(Output{OutputType: "stream", Name: name, Text: textPtr(msg.Text)})
+	case "display_data":
+		k.appendOutput(Output{OutputType: "display_data", Data: msg.Data, Metadata: map[string]any{}})
+	case "execute_result":
+		count := msg.ExecutionCount
+		k.appendOutput(Output{OutputType: "execute_result", Data: msg.Data, Metadata: map[string]any{}, ExecutionCount: &count})
+	case "error":
+		k.appendOutput(Output{OutputType: "error", EName: msg.EName, EValue: msg.EValue, Traceback: msg.Traceback})
+	case "done":
+		k.finishCurrent(msg.Status, msg.ExecutionCount)
+	}
+}
+
+// appendOutput records output for the running cell and streams it.
+// Consecutive writes to the same stream are merged, as Jupyter does.
+func (k *Kernel) appendOutput(out Output) {
+	k.mu.Lock()
+	current := k.current
+	if current == nil {
+		k.mu.Unlock()
+		return
+	}
+	outputs := current.result.Outputs
+	if out.OutputType == "stream" && len(outputs) > 0 {
+		last := &outputs[len(outputs)-1]
+		if last.OutputType == "stream" && last.Name == out.Name && last.Text != nil {
+			merged := *last.Text + *out.Text
+			last.Text = &merged
+		} else {
+			current.result.Outputs = append(outputs, out)
+		}
+	} else {
+		current.result.Outputs = append(outputs, out)
+	}
+	event := Event{
+		KernelID:    k.ID,
+		ExecutionID: current.result.ExecutionID,
+		CellID:      current.result.CellID,
+		Type:        "output",
+		Output:      &out,
+		Timestamp:   time.Now(),
+	}
+	k.publishLocked(event)
+	k.mu.Unlock()
+}
+
+func (k *Kernel) finishCurrent(status string, executionCount int) {
+	k.mu.Lock()
+	current := k.current
+	if current == nil {
+		k.mu.Unlock()
+		return
+	}
+	k.current = nil
+	if status == "" {
+		status = "ok"
+	}
+	if executionCount > 0 {
+		k.executionCount = executionCount
+	}
+	current.result.Status = status
+	current.result.ExecutionCount = k.executionCount
+	current.result.DurationMs = time.Since(current.started).Milliseconds()
+	k.lastActivity = time.Now()
+	k.publishLocked(Event{
+		KernelID:       k.ID,
+		ExecutionID:    current.result.ExecutionID,
+		CellID:         current.result.CellID,
+		Type:           "execute_complete",
+		Status:         status,
+		ExecutionCount: current.result.ExecutionCount,
+		Timestamp:      k.lastActivity,
+	})
+	k.mu.Unlock()
+	close(current.done)
+}
+
+func (k *Kernel) setStatus(status string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.status == status {
+		return
+	}
+	k.status = status
+	k.publishLocked(Event{KernelID: k.ID, Type: "status", Status: status, Timestamp: time.Now()})
+}
+
+func (k *Kernel) publishLocked(event Event) {
+	for _, ch := range k.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Execute runs code in the kernel and returns once the cell completes. If
+// ctx ends first the cell is interrupted and the partial result returned.
+func (k *Kernel) Execute(ctx context.Context, cellID, code string) (*ExecutionResult, error) {
+	k.execMu.Lock()
+	defer k.execMu.Unlock()
+
+	k.mu.Lock()
+	sess := k.session
+	if sess == nil || k.status == StatusDead {
+		k.mu.Unlock()
+		return nil, ErrKernelDead
+	}
+	pending := &pendingExecution{
+		result: &ExecutionResult{
+			ExecutionID: uuid.New().String(),
+			CellID:      cellID,
+			Outputs:     []Output{},
+		},
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	k.current = pending
+	k.lastActivity = pending.started
+	k.mu.Unlock()
+
+	request, err := json.Marshal(map[string]string{"id": pending.result.ExecutionID, "code": code})
+	if err != nil {
+		k.finishCurrent("aborted", 0)
+		return nil, err
+	}
+	k.setStatus(StatusBusy)
+	if _, err := sess.stdin.Write(append(request, '\n')); err != nil {
+		k.finishCurrent("aborted", 0)
+		return nil, fmt.Errorf("failed to send cell to kernel: %w", err)
+	}
+
+	select {
+	case <-pending.done:
+	case <-ctx.Done():
+		if err := sess.proc.Interrupt(); err != nil {
+			log.Printf("notebook kernel %s: interrupt failed: %v", k.ID, err)
+		}
+		select {
+		case <-pending.done:
+		case <-time.After(5 * time.Second):
+			k.finishCurrent("aborted", 0)
+		}
+	}
+	k.mu.Lock()
+	if k.status == StatusBusy {
+		k.status = StatusIdle
+		k.publishLocked(Event{KernelID: k.ID, Type: "status", Status: StatusIdle, Timestamp: time.Now()})
+	}
+	k.mu.Unlock()
+	return pending.result, nil
+}
+
+// Interrupt stops the running cell without discarding kernel state.
+func (k *Kernel) Interrupt() error {
+	k.mu.Lock()
+	sess := k.session
+	busy := k.current != nil
+	k.mu.Unlock()
+	if sess == nil {
+		return ErrKernelDead
+	}
+	if !busy {
+		return nil
+	}
+	return sess.proc.Interrupt()
+}
+
+// stop kills the current process.
+func (k *Kernel) stop() {
+	k.mu.Lock()
+	sess := k.session
+	k.mu.Unlock()
+	if sess == nil {
+		return
+	}
+	sess.stdin.Close()
+	if err := sess.proc.Kill(); err != nil {
+		log.Printf("notebook kernel %s: kill: %v", k.ID, err)
+	}
+}
+
+// closeSubscribers ends every WebSocket stream after shutdown.
+func (k *Kernel) closeSubscribers() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for id, ch := range k.subscribers {
+		delete(k.subscribers, id)
+		close(ch)
+	}
+}
+
+func (k *Kernel) idleSince() (time.Time, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lastActivity, k.current == nil
+}
+
+func textPtr(s string) *MultilineText {
+	t := MultilineText(s)
+	return &t
+}
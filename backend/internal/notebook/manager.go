@@ -0,0 +1,252 @@
+package notebook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"apex-build/internal/execution"
+)
+
+// ErrKernelNotFound is returned for unknown or already shut down kernels.
+var ErrKernelNotFound = errors.New("kernel not found")
+
+// Starter launches a kernel process running command.
+type Starter func(ctx context.Context, language string, command []string, env map[string]string) (Process, error)
+
+// Config controls kernel lifetimes.
+type Config struct {
+	// StartTimeout bounds container start plus interpreter import time.
+	StartTimeout time.Duration
+	// ExecutionTimeout bounds a single cell; the cell is interrupted after it.
+	ExecutionTimeout time.Duration
+	// IdleTimeout shuts down kernels that have not run a cell recently.
+	IdleTimeout time.Duration
+	// MaxKernelsPerUser caps concurrently running kernels per user.
+	MaxKernelsPerUser int
+}
+
+// DefaultConfig returns production kernel limits.
+func DefaultConfig() *Config {
+	return &Config{
+		StartTimeout:      60 * time.Second,
+		ExecutionTimeout:  5 * time.Minute,
+		IdleTimeout:       30 * time.Minute,
+		MaxKernelsPerUser: 3,
+	}
+}
+
+// Manager owns the running kernels.
+type Manager struct {
+	starter Starter
+	config  *Config
+
+	mu      sync.RWMutex
+	kernels map[string]*Kernel
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewManager creates a kernel manager and starts idle cleanup.
+func NewManager(starter Starter, config *Config) *Manager {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	m := &Manager{
+		starter: starter,
+		config:  config,
+		kernels: make(map[string]*Kernel),
+		stopCh:  make(chan struct{}),
+	}
+	go m.cleanupLoop()
+	return m
+}
+
+// SandboxStarter runs kernels in the execution sandbox's containers.
+func SandboxStarter(factory *execution.SandboxFactory) Starter {
+	return func(ctx context.Context, language string, command []string, env map[string]string) (Process, error) {
+		proc, err := factory.StartInteractive(ctx, language, command, env)
+		if err != nil {
+			return nil, err
+		}
+		return sandboxProcess{proc}, nil
+	}
+}
+
+type sandboxProcess struct {
+	*execution.InteractiveProcess
+}
+
+func (p sandboxProcess) Streams() (io.WriteCloser, io.Reader, io.Reader) {
+	return p.Stdin, p.Stdout, p.Stderr
+}
+
+// kernelEnv keeps matplotlib and other caches on the container tmpfs.
+var kernelEnv = map[string]string{
+	"HOME":             "/tmp",
+	"MPLCONFIGDIR":     "/tmp/.matplotlib",
+	"PYTHONUNBUFFERED": "1",
+}
+
+// Start launches a kernel for a project and waits until it is ready.
+func (m *Manager) Start(ctx context.Context, userID, projectID uint, language string) (*Kernel, error) {
+	language = normalizeLanguage(language)
+	if _, err := kernelCommand(language); err != nil {
+		return nil, err
+	}
+	if m.config.MaxKernelsPerUser > 0 && len(m.ListForUser(userID)) >= m.config.MaxKernelsPerUser {
+		return nil, fmt.Errorf("kernel limit reached: shut down a running kernel first (max %d)", m.config.MaxKernelsPerUser)
+	}
+
+	kernel := newKernel(userID, projectID, language)
+	if err := m.launch(ctx, kernel); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.kernels[kernel.ID] = kernel
+	m.mu.Unlock()
+	return kernel, nil
+}
+
+func (m *Manager) launch(ctx context.Context, kernel *Kernel) error {
+	command, err := kernelCommand(kernel.Language)
+	if err != nil {
+		return err
+	}
+	// The process outlives the request that started it.
+	proc, err := m.starter(context.Background(), kernel.Language, command, kernelEnv)
+	if err != nil {
+		return err
+	}
+	sess := kernel.attach(proc)
+
+	startCtx, cancel := context.WithTimeout(ctx, m.config.StartTimeout)
+	defer cancel()
+	if err := kernel.waitReady(startCtx, sess); err != nil {
+		kernel.stop()
+		return err
+	}
+	return nil
+}
+
+// Get returns a kernel by ID.
+func (m *Manager) Get(kernelID string) (*Kernel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	kernel, ok := m.kernels[kernelID]
+	if !ok {
+		return nil, ErrKernelNotFound
+	}
+	return kernel, nil
+}
+
+// ListForUser returns the user's running kernels.
+func (m *Manager) ListForUser(userID uint) []*Kernel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var kernels []*Kernel
+	for _, kernel := range m.kernels {
+		if kernel.UserID == userID {
+			kernels = append(kernels, kernel)
+		}
+	}
+	return kernels
+}
+
+// ExecutionTimeout is the longest a single cell may run.
+func (m *Manager) ExecutionTimeout() time.Duration {
+	return m.config.ExecutionTimeout
+}
+
+// Execute runs one cell with the configured execution timeout.
+func (m *Manager) Execute(ctx context.Context, kernelID, cellID, code string) (*ExecutionResult, error) {
+	kernel, err := m.Get(kernelID)
+	if err != nil {
+		return nil, err
+	}
+	if m.config.ExecutionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.config.ExecutionTimeout)
+		defer cancel()
+	}
+	return kernel.Execute(ctx, cellID, code)
+}
+
+// Restart replaces the kernel process, discarding interpreter state but
+// keeping the kernel ID and its subscribers.
+func (m *Manager) Restart(ctx context.Context, kernelID string) (*Kernel, error) {
+	kernel, err := m.Get(kernelID)
+	if err != nil {
+		return nil, err
+	}
+	kernel.stop()
+	kernel.execMu.Lock()
+	defer kernel.execMu.Unlock()
+	if err := m.launch(ctx, kernel); err != nil {
+		return nil, err
+	}
+	return kernel, nil
+}
+
+// Shutdown stops a kernel and ends its event streams.
+func (m *Manager) Shutdown(kernelID string) error {
+	m.mu.Lock()
+	kernel, ok := m.kernels[kernelID]
+	delete(m.kernels, kernelID)
+	m.mu.Unlock()
+	if !ok {
+		return ErrKernelNotFound
+	}
+	kernel.stop()
+	kernel.closeSubscribers()
+	return nil
+}
+
+// Close shuts down every kernel.
+func (m *Manager) Close() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.kernels))
+	for id := range m.kernels {
+		ids = append(ids, id)
+	}
+	m.mu.RUnlock()
+	for _, id := range ids {
+		m.Shutdown(id)
+	}
+}
+
+func (m *Manager) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			m.reapIdle(time.Now())
+		}
+	}
+}
+
+// reapIdle shuts down dead kernels and idle ones past the idle timeout.
+func (m *Manager) reapIdle(now time.Time) {
+	m.mu.RLock()
+	var expired []string
+	for id, kernel := range m.kernels {
+		last, idle := kernel.idleSince()
+		if kernel.Info().Status == StatusDead || (idle && m.config.IdleTimeout > 0 && now.Sub(last) > m.config.IdleTimeout) {
+			expired = append(expired, id)
+		}
+	}
+	m.mu.RUnlock()
+	for _, id := range expired {
+		log.Printf("notebook: shutting down idle kernel %s", id)
+		m.Shutdown(id)
+	}
+}
@@ -0,0 +1,214 @@
+package notebook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNormalizesSourceAndRoundTrips(t *testing.T) {
+	raw := `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Title\n", "text"]},
+  {"cell_type": "code", "metadata": {"tags": ["x"]}, "source": "print(1)\nx = 2", "execution_count": null, "outputs": [
+   {"output_type": "stream", "name": "stdout", "text": ["1\n"]}
+  ]}
+ ],
+ "metadata": {"kernelspec": {"name": "python3", "language": "python"}, "custom": true},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+	nb, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := string(nb.Cells[0].Source); got != "# Title\ntext" {
+		t.Fatalf("markdown source = %q", got)
+	}
+	if nb.Cells[1].ID != "cell-2" || nb.Language() != "python" {
+		t.Fatalf("cell id = %q, language = %q", nb.Cells[1].ID, nb.Language())
+	}
+
+	out, err := Marshal(nb)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var generic map[string]any
+	if err := json.Unmarshal(out, &generic); err != nil {
+		t.Fatalf("marshalled notebook is not JSON: %v", err)
+	}
+	cells := generic["cells"].([]any)
+	source := cells[1].(map[string]any)["source"].([]any)
+	if len(source) != 2 || source[0] != "print(1)\n" || source[1] != "x = 2" {
+		t.Fatalf("source lines = %#v", source)
+	}
+	if generic["metadata"].(map[string]any)["custom"] != true {
+		t.Fatal("unknown metadata was dropped")
+	}
+
+	if _, err := Parse([]byte(`{"cells": [], "metadata": {}, "nbformat": 3}`)); err == nil {
+		t.Fatal("nbformat 3 should be rejected")
+	}
+	if _, err := Parse([]byte(`{"cells": [{"cell_type": "widget"}], "nbformat": 4}`)); err == nil {
+		t.Fatal("unknown cell types should be rejected")
+	}
+}
+
+// fakeProcess speaks the driver protocol over pipes. respond maps cell code
+// to the lines the kernel writes back before its done message.
+type fakeProcess struct {
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+
+	respond     func(id, code string) []string
+	interrupted chan struct{}
+}
+
+func newFakeProcess(respond func(id, code string) []string) *fakeProcess {
+	p := &fakeProcess{respond: respond, interrupted: make(chan struct{}, 1)}
+	p.stdinR, p.stdinW = io.Pipe()
+	p.stdoutR, p.stdoutW = io.Pipe()
+	go p.serve()
+	return p
+}
+
+func frame(msg map[string]any) string {
+	data, _ := json.Marshal(msg)
+	return messageMarker + string(data)
+}
+
+func (p *fakeProcess) serve() {
+	fmt.Fprintln(p.stdoutW, frame(map[string]any{"type": "ready"}))
+	scanner := bufio.NewScanner(p.stdinR)
+	count := 0
+	for scanner.Scan() {
+		var req struct{ ID, Code string }
+		json.Unmarshal(scanner.Bytes(), &req)
+		count++
+		for _, line := range p.respond(req.ID, req.Code) {
+			fmt.Fprintln(p.stdoutW, line)
+		}
+		if req.Code == "exit" {
+			p.stdoutW.Close()
+			return
+		}
+		if req.Code == "hang" {
+			<-p.interrupted
+		}
+		fmt.Fprintln(p.stdoutW, frame(map[string]any{"id": req.ID, "type": "done", "status": "ok", "execution_count": count}))
+	}
+}
+
+func (p *fakeProcess) Streams() (io.WriteCloser, io.Reader, io.Reader) {
+	return p.stdinW, p.stdoutR, nil
+}
+
+func (p *fakeProcess) Interrupt() error {
+	p.interrupted <- struct{}{}
+	return nil
+}
+
+func (p *fakeProcess) Kill() error {
+	p.stdoutW.Close()
+	return nil
+}
+
+func startFakeKernel(t *testing.T, proc *fakeProcess) (*Manager, *Kernel) {
+	t.Helper()
+	m := NewManager(func(context.Context, string, []string, map[string]string) (Process, error) {
+		return proc, nil
+	}, &Config{StartTimeout: time.Second, ExecutionTimeout: time.Second})
+	t.Cleanup(m.Close)
+	kernel, err := m.Start(context.Background(), 1, 2, "python3")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return m, kernel
+}
+
+func TestKernelExecuteCollectsRichOutputAndStreamsEvents(t *testing.T) {
+	proc := newFakeProcess(func(id, code string) []string {
+		return []string{
+			frame(map[string]any{"id": id, "type": "stream", "name": "stdout", "text": "a"}),
+			frame(map[string]any{"id": id, "type": "stream", "name": "stdout", "text": "b\n"}),
+			"raw subprocess line",
+			frame(map[string]any{"id": id, "type": "display_data", "data": map[string]any{"image/png": "iVBOR"}}),
+			frame(map[string]any{"id": id, "type": "execute_result", "execution_count": 1, "data": map[string]any{
+				"application/json": map[string]any{"columns": []string{"a"}, "data": [][]int{{1}}},
+				"text/plain":       "   a\n0  1",
+			}}),
+		}
+	})
+	m, kernel := startFakeKernel(t, proc)
+	events, unsubscribe := kernel.Subscribe()
+	defer unsubscribe()
+
+	result, err := m.Execute(context.Background(), kernel.ID, "c1", "df")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.Status != "ok" || result.ExecutionCount != 1 || result.CellID != "c1" {
+		t.Fatalf("result = %+v", result)
+	}
+	var types []string
+	for _, out := range result.Outputs {
+		types = append(types, out.OutputType)
+	}
+	if got := strings.Join(types, ","); got != "stream,display_data,execute_result" {
+		t.Fatalf("output types = %s", got)
+	}
+	if got := string(*result.Outputs[0].Text); got != "ab\nraw subprocess line\n" {
+		t.Fatalf("merged stdout = %q", got)
+	}
+	if _, ok := result.Outputs[2].Data["application/json"]; !ok {
+		t.Fatalf("dataframe JSON missing: %+v", result.Outputs[2].Data)
+	}
+
+	var sawOutput, sawComplete bool
+	for !sawComplete {
+		select {
+		case event := <-events:
+			sawOutput = sawOutput || event.Type == "output"
+			sawComplete = event.Type == "execute_complete"
+		case <-time.After(time.Second):
+			t.Fatal("no execute_complete event")
+		}
+	}
+	if !sawOutput {
+		t.Fatal("outputs were not streamed")
+	}
+	if info := kernel.Info(); info.Status != StatusIdle || info.ExecutionCount != 1 {
+		t.Fatalf("kernel info = %+v", info)
+	}
+}
+
+func TestKernelInterruptsOnTimeoutAndReportsExit(t *testing.T) {
+	proc := newFakeProcess(func(string, string) []string { return nil })
+	m, kernel := startFakeKernel(t, proc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := kernel.Execute(ctx, "slow", "hang")
+	if err != nil || result.Status != "ok" {
+		t.Fatalf("interrupted cell = %+v, %v", result, err)
+	}
+
+	result, err = m.Execute(context.Background(), kernel.ID, "bye", "exit")
+	if err != nil || result.Status != "aborted" {
+		t.Fatalf("cell on exiting kernel = %+v, %v", result, err)
+	}
+	if _, err := kernel.Execute(context.Background(), "", "1"); err != ErrKernelDead {
+		t.Fatalf("execute on dead kernel err = %v", err)
+	}
+	m.reapIdle(time.Now())
+	if _, err := m.Get(kernel.ID); err != ErrKernelNotFound {
+		t.Fatal("dead kernels should be reaped")
+	}
+}
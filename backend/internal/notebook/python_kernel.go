@@ -0,0 +1,149 @@
+package notebook
+
+// pythonKernelDriver runs inside the sandbox container. It reads one JSON
+// request per line from stdin, executes the code in a persistent namespace,
+// and writes framed JSON messages to stdout. The last expression of a cell is
+// evaluated and rendered as execute_result. matplotlib figures become
+// image/png, pandas DataFrames become application/json (orient=split, rows
+// capped) alongside text/html, and objects with _repr_*_ methods render as in
+// IPython.
+const pythonKernelDriver = `
+import ast, base64, io, json, signal, sys, traceback
+
+MARK = "\x1eAPEX-NB "
+MAX_ROWS = 500
+_out = sys.__stdout__
+_ns = {"__name__": "__main__"}
+_count = 0
+_current = None
+_busy = False
+
+def _emit(msg):
+    msg["id"] = _current
+    _out.write(MARK + json.dumps(msg, default=str) + "\n")
+    _out.flush()
+
+class _Stream(io.TextIOBase):
+    def __init__(self, name):
+        self.name = name
+    def writable(self):
+        return True
+    def write(self, s):
+        if s:
+            _emit({"type": "stream", "name": self.name, "text": s})
+        return len(s)
+
+def _bundle(value):
+    data = {}
+    try:
+        import pandas as pd
+        if isinstance(value, (pd.DataFrame, pd.Series)):
+            frame = value.to_frame() if isinstance(value, pd.Series) else value
+            data["application/json"] = json.loads(frame.head(MAX_ROWS).to_json(orient="split", date_format="iso", default_handler=str))
+            data["application/json"]["total_rows"] = int(len(frame))
+            data["text/html"] = frame.head(MAX_ROWS).to_html()
+    except ImportError:
+        pass
+    for mime, attr in (("text/html", "_repr_html_"), ("image/png", "_repr_png_"), ("image/svg+xml", "_repr_svg_"), ("text/markdown", "_repr_markdown_"), ("application/json", "_repr_json_")):
+        if mime in data or not hasattr(value, attr):
+            continue
+        try:
+            rendered = getattr(value, attr)()
+        except Exception:
+            continue
+        if rendered is None:
+            continue
+        if isinstance(rendered, bytes):
+            rendered = base64.b64encode(rendered).decode()
+        data[mime] = rendered
+    data["text/plain"] = repr(value)
+    return data
+
+def _flush_figures():
+    if "matplotlib" not in sys.modules:
+        return
+    try:
+        import matplotlib.pyplot as plt
+    except Exception:
+        return
+    for num in plt.get_fignums():
+        buf = io.BytesIO()
+        plt.figure(num).savefig(buf, format="png", bbox_inches="tight")
+        _emit({"type": "display_data", "data": {"image/png": base64.b64encode(buf.getvalue()).decode(), "text/plain": "<Figure>"}})
+    plt.close("all")
+
+def display(*values):
+    for value in values:
+        _emit({"type": "display_data", "data": _bundle(value)})
+
+_ns["display"] = display
+
+def _run(code):
+    tree = ast.parse(code, "<cell>", "exec")
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, "<cell>", "exec"), _ns)
+    if last is not None:
+        value = eval(compile(last, "<cell>", "eval"), _ns)
+        if value is not None:
+            _ns["_"] = value
+            _emit({"type": "execute_result", "data": _bundle(value), "execution_count": _count})
+
+def _interrupt(signum, frame):
+    # An interrupt that races the end of a cell must not kill the idle loop.
+    if _busy:
+        raise KeyboardInterrupt()
+
+signal.signal(signal.SIGINT, _interrupt)
+try:
+    import matplotlib
+    matplotlib.use("Agg")
+except Exception:
+    pass
+
+sys.stdout = _Stream("stdout")
+sys.stderr = _Stream("stderr")
+_emit({"type": "ready"})
+for line in sys.stdin:
+    if not line.strip():
+        continue
+    try:
+        req = json.loads(line)
+    except ValueError:
+        continue
+    _current = req.get("id")
+    _count += 1
+    status = "ok"
+    _busy = True
+    try:
+        _run(req.get("code", ""))
+    except BaseException as exc:
+        if isinstance(exc, SystemExit):
+            status = "ok"
+        else:
+            status = "error"
+            tb = exc.__traceback__
+            while tb is not None and tb.tb_frame.f_code.co_filename != "<cell>":
+                tb = tb.tb_next
+            lines = traceback.format_exception(type(exc), exc, tb)
+            _emit({"type": "error", "ename": type(exc).__name__, "evalue": str(exc), "traceback": [l.rstrip("\n") for l in lines]})
+    _busy = False
+    try:
+        _flush_figures()
+    except Exception:
+        pass
+    sys.stdout.flush()
+    _emit({"type": "done", "status": status, "execution_count": _count})
+`
+
+// kernelCommand returns the container command that starts a kernel for
+// language.
+func kernelCommand(language string) ([]string, error) {
+	switch language {
+	case "python":
+		return []string{"python3", "-u", "-c", pythonKernelDriver}, nil
+	default:
+		return nil, errUnsupportedLanguage(language)
+	}
+}
@@ -0,0 +1,127 @@
+// Package notifications is the in-app notification center. Subsystems post
+// notifications for a user (optionally carrying a follow-up action such as
+// "override"), and the frontend lists and acknowledges them.
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Severity levels for notifications.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// ErrNotFound is returned when a notification does not exist for the user.
+var ErrNotFound = errors.New("notification not found")
+
+// Notification is a single entry in a user's notification center.
+type Notification struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index:idx_notifications_user_read,priority:1" json:"user_id"`
+	Kind      string    `gorm:"not null;size:64" json:"kind"` // e.g. spend_anomaly
+	Severity  string    `gorm:"not null;size:16;default:info" json:"severity"`
+	Title     string    `gorm:"not null;size:255" json:"title"`
+	Body      string    `gorm:"type:text" json:"body"`
+	// Optional follow-up action rendered as a button in the notification center.
+	ActionLabel  string         `gorm:"size:64" json:"action_label,omitempty"`
+	ActionMethod string         `gorm:"size:10" json:"action_method,omitempty"`
+	ActionURL    string         `gorm:"size:500" json:"action_url,omitempty"`
+	Metadata     map[string]any `gorm:"serializer:json" json:"metadata,omitempty"`
+	ReadAt       *time.Time     `gorm:"index:idx_notifications_user_read,priority:2" json:"read_at,omitempty"`
+}
+
+func (Notification) TableName() string { return "notifications" }
+
+// Service persists and queries notifications.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new notification Service backed by the given database.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Notify stores a notification for n.UserID.
+func (s *Service) Notify(ctx context.Context, n *Notification) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("notifications: database unavailable")
+	}
+	if n == nil || n.UserID == 0 {
+		return fmt.Errorf("notifications: user id is required")
+	}
+	if n.Severity == "" {
+		n.Severity = SeverityInfo
+	}
+	if err := s.db.WithContext(ctx).Create(n).Error; err != nil {
+		return fmt.Errorf("notifications: create failed: %w", err)
+	}
+	return nil
+}
+
+// List returns the newest notifications for a user and the unread count.
+func (s *Service) List(ctx context.Context, userID uint, unreadOnly bool, limit int) ([]Notification, int64, error) {
+	if s == nil || s.db == nil {
+		return nil, 0, fmt.Errorf("notifications: database unavailable")
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	var items []Notification
+	if err := query.Order("created_at DESC").Limit(limit).Find(&items).Error; err != nil {
+		return nil, 0, fmt.Errorf("notifications: list failed: %w", err)
+	}
+	var unread int64
+	if err := s.db.WithContext(ctx).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&unread).Error; err != nil {
+		return nil, 0, fmt.Errorf("notifications: unread count failed: %w", err)
+	}
+	return items, unread, nil
+}
+
+// MarkRead marks one notification as read.
+func (s *Service) MarkRead(ctx context.Context, userID, id uint) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("notifications: database unavailable")
+	}
+	now := time.Now().UTC()
+	res := s.db.WithContext(ctx).Model(&Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", now)
+	if res.Error != nil {
+		return fmt.Errorf("notifications: mark read failed: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification for the user as read.
+func (s *Service) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("notifications: database unavailable")
+	}
+	res := s.db.WithContext(ctx).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now().UTC())
+	if res.Error != nil {
+		return 0, fmt.Errorf("notifications: mark all read failed: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
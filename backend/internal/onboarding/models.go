@@ -0,0 +1,96 @@
+// Package onboarding tracks each user's guided setup: a sample project
+// provisioned on first sign-in and a checklist of first steps.
+package onboarding
+
+import "time"
+
+// Checklist events. Every step completes on one of them: the automatic
+// events are detected from the user's activity, manual steps are ticked
+// off by the user.
+const (
+	EventSampleProject  = "sample_project"
+	EventRanCode        = "ran_code"
+	EventUsedAI         = "used_ai"
+	EventDeployed       = "deployed"
+	EventCreatedProject = "created_project"
+	EventManual         = "manual"
+)
+
+// Step is one item of an onboarding checklist
+type Step struct {
+	Key         string `json:"key"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Event       string `json:"event"`
+	Optional    bool   `json:"optional,omitempty"`
+}
+
+// Checklist is an organization's customized onboarding checklist. Members
+// of the organization see it in place of the default.
+type Checklist struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID   uint   `json:"organization_id" gorm:"uniqueIndex;not null"`
+	Steps            []Step `json:"steps" gorm:"type:text;serializer:json"`
+	SampleTemplateID string `json:"sample_template_id" gorm:"size:64"`
+	SkipSample       bool   `json:"skip_sample"`
+	UpdatedBy        uint   `json:"updated_by"`
+}
+
+// TableName specifies the table name for Checklist
+func (Checklist) TableName() string {
+	return "onboarding_checklists"
+}
+
+// UserState is a user's onboarding record, created on first sign-in
+type UserState struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID   *uint      `json:"organization_id,omitempty"`
+	SampleProjectID  *uint      `json:"sample_project_id,omitempty"`
+	SampleTemplateID string     `json:"sample_template_id,omitempty" gorm:"size:64"`
+	ProvisionError   string     `json:"provision_error,omitempty" gorm:"size:255"`
+	DismissedAt      *time.Time `json:"dismissed_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName specifies the table name for UserState
+func (UserState) TableName() string {
+	return "onboarding_states"
+}
+
+// StepCompletion records when a user finished a checklist step
+type StepCompletion struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_onboarding_completion"`
+	StepKey     string    `json:"step_key" gorm:"size:64;not null;uniqueIndex:idx_onboarding_completion"`
+	Event       string    `json:"event" gorm:"size:32"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// TableName specifies the table name for StepCompletion
+func (StepCompletion) TableName() string {
+	return "onboarding_step_completions"
+}
+
+// StepProgress is a checklist step with the user's completion
+type StepProgress struct {
+	Step
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Progress is what the frontend needs to render guided setup
+type Progress struct {
+	State          *UserState     `json:"state"`
+	Steps          []StepProgress `json:"steps"`
+	CompletedSteps int            `json:"completed_steps"`
+	TotalSteps     int            `json:"total_steps"`
+	Percent        int            `json:"percent"`
+	Completed      bool           `json:"completed"`
+	Customized     bool           `json:"customized"`
+}
@@ -0,0 +1,353 @@
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"apex-build/internal/deploy"
+	"apex-build/internal/hosting"
+	"apex-build/internal/templates"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultSampleTemplateID is the template new users' sample project is
+// created from. It runs in the browser preview with no dependencies.
+const DefaultSampleTemplateID = "vanilla-js"
+
+// sampleProjectName names the provisioned sample project
+const sampleProjectName = "Welcome to APEX.BUILD"
+
+// maxChecklistSteps caps a customized checklist
+const maxChecklistSteps = 20
+
+var (
+	ErrInvalidChecklist = errors.New("invalid onboarding checklist")
+	ErrUnknownStep      = errors.New("unknown onboarding step")
+	ErrStepNotManual    = errors.New("onboarding step completes automatically")
+)
+
+var stepKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// DefaultChecklist is the checklist shown to users whose organizations have
+// not customized one
+func DefaultChecklist() []Step {
+	return []Step{
+		{Key: "open_sample", Title: "Open your sample project", Description: "We created a starter project so you can look around.", Event: EventSampleProject},
+		{Key: "run_code", Title: "Run your code", Description: "Press Run to execute a project and see its output.", Event: EventRanCode},
+		{Key: "use_ai", Title: "Ask the AI", Description: "Generate, explain or fix code with the AI assistant.", Event: EventUsedAI},
+		{Key: "deploy", Title: "Deploy an app", Description: "Publish a project to a live URL.", Event: EventDeployed},
+		{Key: "create_project", Title: "Start your own project", Description: "Create a project from scratch or a template.", Event: EventCreatedProject, Optional: true},
+	}
+}
+
+// Service provisions sample projects and tracks checklist progress
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates an onboarding service
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// checklistFor returns the checklist that applies to a user: the first of
+// their organizations that customized one, or the default
+func (s *Service) checklistFor(userID uint) (*Checklist, bool) {
+	var checklist Checklist
+	err := s.db.Model(&Checklist{}).
+		Joins("JOIN organization_members ON organization_members.organization_id = onboarding_checklists.organization_id").
+		Where("organization_members.user_id = ? AND organization_members.status = ? AND organization_members.deleted_at IS NULL", userID, "active").
+		Order("onboarding_checklists.organization_id ASC").
+		First(&checklist).Error
+	if err != nil {
+		return &Checklist{Steps: DefaultChecklist(), SampleTemplateID: DefaultSampleTemplateID}, false
+	}
+	return &checklist, true
+}
+
+// Start creates a user's onboarding record and provisions their sample
+// project. It is called on every sign-in and only acts the first time.
+func (s *Service) Start(userID uint) (*UserState, error) {
+	var existing UserState
+	if err := s.db.First(&existing, "user_id = ?", userID).Error; err == nil {
+		return &existing, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	checklist, customized := s.checklistFor(userID)
+	state := &UserState{UserID: userID}
+	if customized {
+		state.OrganizationID = &checklist.OrganizationID
+	}
+
+	// Concurrent sign-ins race to create the record; only the winner
+	// provisions the sample project
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := s.db.First(&existing, "user_id = ?", userID).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	if checklist.SkipSample {
+		return state, nil
+	}
+	templateID := checklist.SampleTemplateID
+	if templateID == "" {
+		templateID = DefaultSampleTemplateID
+	}
+	updates := map[string]interface{}{"sample_template_id": templateID}
+	template, err := templates.GetTemplateByID(templateID)
+	if err == nil {
+		var project *models.Project
+		project, _, err = templates.CreateProject(s.db, userID, template, sampleProjectName, "Your sample project. Edit, run and deploy it to learn the ropes.")
+		if err == nil {
+			updates["sample_project_id"] = project.ID
+			state.SampleProjectID = &project.ID
+		}
+	}
+	if err != nil {
+		log.Printf("onboarding: sample project for user %d: %v", userID, err)
+		updates["provision_error"] = truncate(err.Error(), 255)
+		state.ProvisionError = truncate(err.Error(), 255)
+	}
+	state.SampleTemplateID = templateID
+	if err := s.db.Model(&UserState{}).Where("user_id = ?", userID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// detect reports whether the user's activity already satisfies an
+// automatic event
+func (s *Service) detect(userID uint, event string, state *UserState) bool {
+	exists := func(query *gorm.DB) bool {
+		var n int64
+		if err := query.Limit(1).Count(&n).Error; err != nil {
+			return false
+		}
+		return n > 0
+	}
+	switch event {
+	case EventSampleProject:
+		return state.SampleProjectID != nil
+	case EventRanCode:
+		return exists(s.db.Model(&models.Execution{}).Where("user_id = ?", userID))
+	case EventUsedAI:
+		return exists(s.db.Model(&models.AIRequest{}).Where("user_id = ?", userID)) ||
+			exists(s.db.Model(&models.CompletedBuild{}).Where("user_id = ?", userID))
+	case EventDeployed:
+		return exists(s.db.Model(&hosting.NativeDeployment{}).Where("user_id = ?", userID)) ||
+			exists(s.db.Model(&deploy.Deployment{}).Where("user_id = ?", userID))
+	case EventCreatedProject:
+		query := s.db.Model(&models.Project{}).Where("owner_id = ?", userID)
+		if state.SampleProjectID != nil {
+			query = query.Where("id <> ?", *state.SampleProjectID)
+		}
+		return exists(query)
+	}
+	return false
+}
+
+// Progress returns the user's checklist with completion, recording steps
+// newly satisfied by their activity
+func (s *Service) Progress(userID uint) (*Progress, error) {
+	state, err := s.Start(userID)
+	if err != nil {
+		return nil, err
+	}
+	checklist, customized := s.checklistFor(userID)
+
+	var completions []StepCompletion
+	if err := s.db.Where("user_id = ?", userID).Find(&completions).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[string]time.Time, len(completions))
+	for _, completion := range completions {
+		done[completion.StepKey] = completion.CompletedAt
+	}
+
+	progress := &Progress{State: state, Customized: customized}
+	required, requiredDone := 0, 0
+	for _, step := range checklist.Steps {
+		completedAt, ok := done[step.Key]
+		if !ok && step.Event != EventManual && s.detect(userID, step.Event, state) {
+			completedAt, ok = time.Now(), true
+			s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&StepCompletion{
+				UserID:      userID,
+				StepKey:     step.Key,
+				Event:       step.Event,
+				CompletedAt: completedAt,
+			})
+		}
+
+		item := StepProgress{Step: step, Completed: ok}
+		if ok {
+			at := completedAt
+			item.CompletedAt = &at
+			progress.CompletedSteps++
+		}
+		if !step.Optional {
+			required++
+			if ok {
+				requiredDone++
+			}
+		}
+		progress.Steps = append(progress.Steps, item)
+	}
+
+	progress.TotalSteps = len(checklist.Steps)
+	if progress.TotalSteps > 0 {
+		progress.Percent = progress.CompletedSteps * 100 / progress.TotalSteps
+	}
+	progress.Completed = requiredDone == required
+	if progress.Completed && state.CompletedAt == nil {
+		now := time.Now()
+		state.CompletedAt = &now
+		s.db.Model(&UserState{}).Where("user_id = ?", userID).Update("completed_at", now)
+	}
+	return progress, nil
+}
+
+// CompleteStep ticks off a manual checklist step
+func (s *Service) CompleteStep(userID uint, key string) (*Progress, error) {
+	checklist, _ := s.checklistFor(userID)
+	var step *Step
+	for i := range checklist.Steps {
+		if checklist.Steps[i].Key == key {
+			step = &checklist.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStep, key)
+	}
+	if step.Event != EventManual {
+		return nil, fmt.Errorf("%w: %s", ErrStepNotManual, key)
+	}
+	err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&StepCompletion{
+		UserID:      userID,
+		StepKey:     key,
+		Event:       EventManual,
+		CompletedAt: time.Now(),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.Progress(userID)
+}
+
+// Dismiss hides guided setup for a user without completing it
+func (s *Service) Dismiss(userID uint) error {
+	if _, err := s.Start(userID); err != nil {
+		return err
+	}
+	return s.db.Model(&UserState{}).Where("user_id = ?", userID).Update("dismissed_at", time.Now()).Error
+}
+
+// GetChecklist returns an organization's checklist, or the default when
+// it has not customized one
+func (s *Service) GetChecklist(orgID uint) (*Checklist, bool, error) {
+	var checklist Checklist
+	err := s.db.Where("organization_id = ?", orgID).First(&checklist).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Checklist{OrganizationID: orgID, Steps: DefaultChecklist(), SampleTemplateID: DefaultSampleTemplateID}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &checklist, true, nil
+}
+
+// SaveChecklist validates and stores an organization's checklist
+func (s *Service) SaveChecklist(orgID, actorID uint, checklist *Checklist) (*Checklist, error) {
+	if err := checklist.normalize(); err != nil {
+		return nil, err
+	}
+
+	var saved Checklist
+	err := s.db.Where("organization_id = ?", orgID).First(&saved).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	saved.OrganizationID = orgID
+	saved.Steps = checklist.Steps
+	saved.SampleTemplateID = checklist.SampleTemplateID
+	saved.SkipSample = checklist.SkipSample
+	saved.UpdatedBy = actorID
+	if err := s.db.Save(&saved).Error; err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// ResetChecklist restores the default checklist for an organization
+func (s *Service) ResetChecklist(orgID uint) error {
+	return s.db.Where("organization_id = ?", orgID).Delete(&Checklist{}).Error
+}
+
+// normalize trims a checklist and rejects unknown events, duplicate keys
+// and templates that do not exist
+func (c *Checklist) normalize() error {
+	if len(c.Steps) == 0 || len(c.Steps) > maxChecklistSteps {
+		return fmt.Errorf("%w: between 1 and %d steps are required", ErrInvalidChecklist, maxChecklistSteps)
+	}
+	seen := make(map[string]bool, len(c.Steps))
+	for i := range c.Steps {
+		step := &c.Steps[i]
+		step.Key = strings.ToLower(strings.TrimSpace(step.Key))
+		step.Title = strings.TrimSpace(step.Title)
+		step.Description = strings.TrimSpace(step.Description)
+		if !stepKeyPattern.MatchString(step.Key) {
+			return fmt.Errorf("%w: step key %q must be lowercase letters, digits, '-' or '_'", ErrInvalidChecklist, step.Key)
+		}
+		if seen[step.Key] {
+			return fmt.Errorf("%w: step key %q is used twice", ErrInvalidChecklist, step.Key)
+		}
+		seen[step.Key] = true
+		if step.Title == "" || len(step.Title) > 120 || len(step.Description) > 500 {
+			return fmt.Errorf("%w: step %q needs a title of at most 120 characters and a description of at most 500", ErrInvalidChecklist, step.Key)
+		}
+		if step.Event == EventSampleProject && c.SkipSample {
+			return fmt.Errorf("%w: step %q waits for a sample project, but skip_sample is set", ErrInvalidChecklist, step.Key)
+		}
+		switch step.Event {
+		case EventSampleProject, EventRanCode, EventUsedAI, EventDeployed, EventCreatedProject, EventManual:
+		default:
+			return fmt.Errorf("%w: step %q has unknown event %q", ErrInvalidChecklist, step.Key, step.Event)
+		}
+	}
+
+	c.SampleTemplateID = strings.TrimSpace(c.SampleTemplateID)
+	if c.SampleTemplateID == "" {
+		c.SampleTemplateID = DefaultSampleTemplateID
+	}
+	if _, err := templates.GetTemplateByID(c.SampleTemplateID); err != nil {
+		return fmt.Errorf("%w: unknown sample template %q", ErrInvalidChecklist, c.SampleTemplateID)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// AutoMigrate creates the onboarding tables
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Checklist{}, &UserState{}, &StepCompletion{})
+}
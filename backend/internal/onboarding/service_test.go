@@ -0,0 +1,160 @@
+package onboarding
+
+import (
+	"errors"
+	"testing"
+
+	"apex-build/internal/deploy"
+	"apex-build/internal/hosting"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}, &models.Execution{}, &models.AIRequest{}, &models.CompletedBuild{}, &hosting.NativeDeployment{}, &deploy.Deployment{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE organization_members (id INTEGER PRIMARY KEY, organization_id INTEGER, user_id INTEGER, status TEXT, deleted_at DATETIME)").Error; err != nil {
+		t.Fatalf("create organization_members: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate onboarding: %v", err)
+	}
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestStartProvisionsSampleOnce(t *testing.T) {
+	db := openTestDB(t)
+	user := createUser(t, db, "ada")
+	service := NewService(db)
+
+	state, err := service.Start(user.ID)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if state.SampleProjectID == nil || state.ProvisionError != "" {
+		t.Fatalf("state = %+v, want a sample project", state)
+	}
+	var files int64
+	db.Model(&models.File{}).Where("project_id = ?", *state.SampleProjectID).Count(&files)
+	if files == 0 {
+		t.Fatal("sample project has no files")
+	}
+
+	if _, err := service.Start(user.ID); err != nil {
+		t.Fatalf("second start: %v", err)
+	}
+	var projects int64
+	db.Model(&models.Project{}).Where("owner_id = ?", user.ID).Count(&projects)
+	if projects != 1 {
+		t.Fatalf("projects = %d, want 1", projects)
+	}
+}
+
+func TestProgressDetectsActivity(t *testing.T) {
+	db := openTestDB(t)
+	user := createUser(t, db, "grace")
+	service := NewService(db)
+
+	progress, err := service.Progress(user.ID)
+	if err != nil {
+		t.Fatalf("progress: %v", err)
+	}
+	if progress.CompletedSteps != 1 || progress.Completed || progress.Steps[0].Key != "open_sample" || !progress.Steps[0].Completed {
+		t.Fatalf("progress = %+v, want only the sample step done", progress)
+	}
+
+	if err := db.Create(&models.Execution{ExecutionID: "exec-1", UserID: user.ID, Language: "javascript", Status: "completed"}).Error; err != nil {
+		t.Fatalf("create execution: %v", err)
+	}
+	progress, err = service.Progress(user.ID)
+	if err != nil {
+		t.Fatalf("progress: %v", err)
+	}
+	if !progress.Steps[1].Completed || progress.CompletedSteps != 2 || progress.Percent != 40 {
+		t.Fatalf("progress = %+v, want run_code done", progress)
+	}
+
+	if _, err := service.CompleteStep(user.ID, "run_code"); !errors.Is(err, ErrStepNotManual) {
+		t.Fatalf("complete automatic step err = %v", err)
+	}
+	if _, err := service.CompleteStep(user.ID, "missing"); !errors.Is(err, ErrUnknownStep) {
+		t.Fatalf("complete unknown step err = %v", err)
+	}
+}
+
+func TestOrganizationChecklist(t *testing.T) {
+	db := openTestDB(t)
+	admin := createUser(t, db, "admin")
+	member := createUser(t, db, "member")
+	service := NewService(db)
+	if err := db.Exec("INSERT INTO organization_members (organization_id, user_id, status) VALUES (7, ?, 'active')", member.ID).Error; err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	invalid := []*Checklist{
+		{},
+		{Steps: []Step{{Key: "Bad Key", Title: "x", Event: EventManual}}},
+		{Steps: []Step{{Key: "a", Title: "x", Event: "teleported"}}},
+		{Steps: []Step{{Key: "a", Title: "x", Event: EventManual}, {Key: "a", Title: "y", Event: EventManual}}},
+		{Steps: []Step{{Key: "a", Title: "x", Event: EventSampleProject}}, SkipSample: true},
+		{Steps: []Step{{Key: "a", Title: "x", Event: EventManual}}, SampleTemplateID: "no-such-template"},
+	}
+	for i, checklist := range invalid {
+		if _, err := service.SaveChecklist(7, admin.ID, checklist); !errors.Is(err, ErrInvalidChecklist) {
+			t.Fatalf("checklist %d err = %v, want ErrInvalidChecklist", i, err)
+		}
+	}
+
+	_, err := service.SaveChecklist(7, admin.ID, &Checklist{
+		SkipSample: true,
+		Steps: []Step{
+			{Key: "read-handbook", Title: "Read the engineering handbook", Event: EventManual},
+			{Key: "run_code", Title: "Run something", Event: EventRanCode, Optional: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("save checklist: %v", err)
+	}
+
+	progress, err := service.Progress(member.ID)
+	if err != nil {
+		t.Fatalf("progress: %v", err)
+	}
+	if !progress.Customized || progress.TotalSteps != 2 || progress.State.SampleProjectID != nil {
+		t.Fatalf("progress = %+v, want the org checklist without a sample", progress)
+	}
+	progress, err = service.CompleteStep(member.ID, "read-handbook")
+	if err != nil {
+		t.Fatalf("complete step: %v", err)
+	}
+	if !progress.Completed || progress.State.CompletedAt == nil {
+		t.Fatalf("progress = %+v, want completed with only optional steps left", progress)
+	}
+
+	if progress, _ := service.Progress(admin.ID); progress.Customized || progress.TotalSteps != len(DefaultChecklist()) {
+		t.Fatalf("non-member progress = %+v, want the default checklist", progress)
+	}
+	if err := service.ResetChecklist(7); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, customized, _ := service.GetChecklist(7); customized {
+		t.Fatal("checklist still customized after reset")
+	}
+}
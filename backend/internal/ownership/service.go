@@ -0,0 +1,470 @@
+// Package ownership manages who owns a project. A project is owned either by
+// a user (owner_id) or by an organization (organization_id), in which case
+// members see it according to their RBAC role and it counts against the
+// organization's project and storage quotas instead of the member's plan.
+// An organization project's owner_id only records who created it or last
+// transferred it in; it grants no access and the project is not deleted
+// with that user's account. Moving a project
+// between owners goes through a transfer that the receiving side confirms.
+package ownership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// TransferTTL is how long a transfer waits for the recipient to respond.
+const TransferTTL = 7 * 24 * time.Hour
+
+// Transfer statuses.
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+	TransferExpired   = "expired"
+)
+
+var (
+	// ErrProjectNotFound is returned when the project does not exist.
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrOrgNotFound is returned when the organization does not exist.
+	ErrOrgNotFound = errors.New("organization not found")
+	// ErrUserNotFound is returned when the recipient user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrForbidden is returned when the caller lacks the required permission.
+	ErrForbidden = errors.New("insufficient permission for this project")
+	// ErrInvalidTarget is returned for a missing, ambiguous, or no-op target.
+	ErrInvalidTarget = errors.New("transfer needs exactly one new owner different from the current one")
+	// ErrTransferNotFound is returned when the transfer does not exist or is
+	// not visible to the caller.
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrTransferClosed is returned when responding to a non-pending transfer.
+	ErrTransferClosed = errors.New("transfer is no longer pending")
+	// ErrOrgProjectQuota is returned when the organization is at MaxProjects.
+	ErrOrgProjectQuota = errors.New("organization project limit reached")
+	// ErrUserProjectQuota is returned when the recipient user is at their
+	// plan's project limit.
+	ErrUserProjectQuota = errors.New("recipient project limit reached")
+)
+
+// ProjectTransfer is a pending or completed change of project owner. Exactly
+// one of FromUserID/FromOrgID and one of ToUserID/ToOrgID is set.
+type ProjectTransfer struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID   uint       `json:"project_id" gorm:"not null;index"`
+	ProjectName string     `json:"project_name" gorm:"size:255"`
+	FromUserID  *uint      `json:"from_user_id,omitempty" gorm:"index"`
+	FromOrgID   *uint      `json:"from_organization_id,omitempty" gorm:"index"`
+	ToUserID    *uint      `json:"to_user_id,omitempty" gorm:"index"`
+	ToOrgID     *uint      `json:"to_organization_id,omitempty" gorm:"index"`
+	InitiatedBy uint       `json:"initiated_by" gorm:"not null"`
+	Status      string     `json:"status" gorm:"size:20;not null;index"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedBy *uint      `json:"responded_by,omitempty"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// TableName keeps the table name explicit.
+func (ProjectTransfer) TableName() string { return "project_transfers" }
+
+// Target names the new owner of a transfer.
+type Target struct {
+	UserID         *uint `json:"to_user_id"`
+	OrganizationID *uint `json:"to_organization_id"`
+}
+
+// Permissions answers RBAC questions. *enterprise.RBACService satisfies it.
+type Permissions interface {
+	HasPermission(orgID, userID uint, resource, action string) bool
+}
+
+// UserProjectQuota reports whether a user's plan allows one more project.
+type UserProjectQuota interface {
+	AllowProject(ctx context.Context, userID uint) (bool, error)
+}
+
+// UsageRefresher invalidates cached usage after project counts change.
+type UsageRefresher interface {
+	ForceRefresh(ctx context.Context, userID uint) error
+}
+
+// ProjectTokenOwner re-encrypts a project's service token for its new
+// owner. *secrets.ProjectTokens satisfies it.
+type ProjectTokenOwner interface {
+	Reassign(projectID, userID uint) error
+}
+
+// Service creates organization projects and runs ownership transfers.
+type Service struct {
+	db     *gorm.DB
+	perms  Permissions
+	quota  UserProjectQuota
+	usage  UsageRefresher
+	tokens ProjectTokenOwner
+	now    func() time.Time
+}
+
+// NewService creates a new ownership Service.
+func NewService(db *gorm.DB, perms Permissions) *Service {
+	return &Service{db: db, perms: perms, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// SetUserProjectQuota wires the plan check used when a user receives a project.
+func (s *Service) SetUserProjectQuota(q UserProjectQuota) { s.quota = q }
+
+// SetUsageRefresher wires usage cache invalidation.
+func (s *Service) SetUsageRefresher(r UsageRefresher) { s.usage = r }
+
+// SetProjectTokens wires service token re-encryption for user transfers.
+func (s *Service) SetProjectTokens(t ProjectTokenOwner) { s.tokens = t }
+
+// ReadableOrgIDs returns the organizations whose projects the user may see.
+func (s *Service) ReadableOrgIDs(ctx context.Context, userID uint) ([]uint, error) {
+	var orgIDs []uint
+	err := s.db.WithContext(ctx).Model(&enterprise.OrganizationMember{}).
+		Where("user_id = ? AND status = ?", userID, "active").
+		Pluck("organization_id", &orgIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("ownership: load memberships failed: %w", err)
+	}
+	readable := orgIDs[:0]
+	for _, orgID := range orgIDs {
+		if s.perms.HasPermission(orgID, userID, "projects", "read") {
+			readable = append(readable, orgID)
+		}
+	}
+	return readable, nil
+}
+
+// CanAccess reports whether the user may perform action ("read", "update",
+// "delete", "manage") on the project. The user owner may do anything; org
+// projects defer to the member's role.
+func (s *Service) CanAccess(userID uint, project *models.Project, action string) bool {
+	if project.OrganizationID == nil {
+		return project.OwnerID == userID
+	}
+	return s.perms.HasPermission(*project.OrganizationID, userID, "projects", action)
+}
+
+// CreateOrgProject creates a project owned by the organization. The creator
+// is recorded as owner_id for attribution only; access comes from RBAC and
+// the project counts against the org's quota.
+func (s *Service) CreateOrgProject(ctx context.Context, userID, orgID uint, project *models.Project) error {
+	org, err := s.loadOrg(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if !s.perms.HasPermission(orgID, userID, "projects", "create") {
+		return ErrForbidden
+	}
+	if err := s.checkOrgQuota(ctx, org); err != nil {
+		return err
+	}
+	project.OwnerID = userID
+	project.OrganizationID = &org.ID
+	if err := s.db.WithContext(ctx).Create(project).Error; err != nil {
+		return fmt.Errorf("ownership: create project failed: %w", err)
+	}
+	return nil
+}
+
+// ListOrgProjects returns the organization's projects for a member with
+// projects:read.
+func (s *Service) ListOrgProjects(ctx context.Context, userID, orgID uint) ([]models.Project, error) {
+	if _, err := s.loadOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	if !s.perms.HasPermission(orgID, userID, "projects", "read") {
+		return nil, ErrForbidden
+	}
+	var projects []models.Project
+	err := s.db.WithContext(ctx).Where("organization_id = ?", orgID).Order("updated_at DESC").Find(&projects).Error
+	if err != nil {
+		return nil, fmt.Errorf("ownership: list projects failed: %w", err)
+	}
+	return projects, nil
+}
+
+// InitiateTransfer starts moving a project to a new owner. The caller must be
+// the user owner, or hold projects:manage in the owning organization. Any
+// earlier pending transfer of the same project is cancelled.
+func (s *Service) InitiateTransfer(ctx context.Context, userID, projectID uint, target Target) (*ProjectTransfer, error) {
+	project, err := s.loadProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.CanAccess(userID, project, "manage") {
+		return nil, ErrForbidden
+	}
+	if (target.UserID == nil) == (target.OrganizationID == nil) {
+		return nil, ErrInvalidTarget
+	}
+
+	transfer := ProjectTransfer{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		InitiatedBy: userID,
+		Status:      TransferPending,
+		ExpiresAt:   s.now().Add(TransferTTL),
+	}
+	if project.OrganizationID != nil {
+		transfer.FromOrgID = project.OrganizationID
+	} else {
+		owner := project.OwnerID
+		transfer.FromUserID = &owner
+	}
+
+	switch {
+	case target.UserID != nil:
+		if project.OrganizationID == nil && *target.UserID == project.OwnerID {
+			return nil, ErrInvalidTarget
+		}
+		var count int64
+		s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", *target.UserID).Count(&count)
+		if count == 0 {
+			return nil, ErrUserNotFound
+		}
+		transfer.ToUserID = target.UserID
+	default:
+		if project.OrganizationID != nil && *project.OrganizationID == *target.OrganizationID {
+			return nil, ErrInvalidTarget
+		}
+		if _, err := s.loadOrg(ctx, *target.OrganizationID); err != nil {
+			return nil, err
+		}
+		transfer.ToOrgID = target.OrganizationID
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ProjectTransfer{}).
+			Where("project_id = ? AND status = ?", project.ID, TransferPending).
+			Update("status", TransferCancelled).Error; err != nil {
+			return err
+		}
+		return tx.Create(&transfer).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ownership: create transfer failed: %w", err)
+	}
+	return &transfer, nil
+}
+
+// ListTransfers returns pending transfers the user can respond to or cancel.
+func (s *Service) ListTransfers(ctx context.Context, userID uint) ([]ProjectTransfer, error) {
+	var candidates []ProjectTransfer
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND expires_at > ?", TransferPending, s.now()).
+		Order("created_at DESC").Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("ownership: list transfers failed: %w", err)
+	}
+	visible := make([]ProjectTransfer, 0, len(candidates))
+	for _, t := range candidates {
+		if t.InitiatedBy == userID || s.canReceive(userID, &t) {
+			visible = append(visible, t)
+		}
+	}
+	return visible, nil
+}
+
+// AcceptTransfer confirms a transfer on behalf of the recipient and moves the
+// project. Receiving organizations need projects:manage from the caller; the
+// previous owner stays in owner_id for attribution and keeps only the access
+// their role in the organization gives them.
+func (s *Service) AcceptTransfer(ctx context.Context, userID, transferID uint) (*ProjectTransfer, error) {
+	transfer, err := s.openTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.canReceive(userID, transfer) {
+		return nil, ErrTransferNotFound
+	}
+	project, err := s.loadProject(ctx, transfer.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !sameOwner(project, transfer) {
+		// The project changed hands since the transfer was offered.
+		s.close(ctx, transfer, TransferCancelled, userID)
+		return nil, ErrTransferClosed
+	}
+
+	updates := map[string]interface{}{}
+	if transfer.ToOrgID != nil {
+		org, err := s.loadOrg(ctx, *transfer.ToOrgID)
+		if err != nil {
+			return nil, err
+		}
+		if !project.IsArchived {
+			if err := s.checkOrgQuota(ctx, org); err != nil {
+				return nil, err
+			}
+		}
+		updates["organization_id"] = org.ID
+	} else {
+		if s.quota != nil && !project.IsArchived {
+			allowed, err := s.quota.AllowProject(ctx, *transfer.ToUserID)
+			if err != nil {
+				return nil, fmt.Errorf("ownership: check recipient quota failed: %w", err)
+			}
+			if !allowed {
+				return nil, ErrUserProjectQuota
+			}
+		}
+		updates["organization_id"] = nil
+		updates["owner_id"] = *transfer.ToUserID
+	}
+
+	now := s.now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Project{}).Where("id = ?", project.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Model(transfer).Updates(map[string]interface{}{
+			"status":       TransferAccepted,
+			"responded_by": userID,
+			"responded_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ownership: apply transfer failed: %w", err)
+	}
+
+	// The token keeps working for its consumers either way; moving it to the
+	// new owner's key means it no longer depends on the previous owner.
+	if s.tokens != nil && transfer.ToUserID != nil {
+		if err := s.tokens.Reassign(project.ID, *transfer.ToUserID); err != nil {
+			log.Printf("ownership: re-encrypt service token of project %d for user %d: %v", project.ID, *transfer.ToUserID, err)
+		}
+	}
+
+	if s.usage != nil {
+		_ = s.usage.ForceRefresh(ctx, project.OwnerID)
+		if transfer.ToUserID != nil {
+			_ = s.usage.ForceRefresh(ctx, *transfer.ToUserID)
+		}
+	}
+	return transfer, nil
+}
+
+// DeclineTransfer rejects a transfer on behalf of the recipient.
+func (s *Service) DeclineTransfer(ctx context.Context, userID, transferID uint) (*ProjectTransfer, error) {
+	transfer, err := s.openTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.canReceive(userID, transfer) {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, s.close(ctx, transfer, TransferDeclined, userID)
+}
+
+// CancelTransfer withdraws a transfer; only its initiator may cancel.
+func (s *Service) CancelTransfer(ctx context.Context, userID, transferID uint) (*ProjectTransfer, error) {
+	transfer, err := s.openTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.InitiatedBy != userID {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, s.close(ctx, transfer, TransferCancelled, userID)
+}
+
+func (s *Service) canReceive(userID uint, t *ProjectTransfer) bool {
+	if t.ToUserID != nil {
+		return *t.ToUserID == userID
+	}
+	return t.ToOrgID != nil && s.perms.HasPermission(*t.ToOrgID, userID, "projects", "manage")
+}
+
+func (s *Service) openTransfer(ctx context.Context, transferID uint) (*ProjectTransfer, error) {
+	var transfer ProjectTransfer
+	err := s.db.WithContext(ctx).First(&transfer, transferID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTransferNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ownership: load transfer failed: %w", err)
+	}
+	if transfer.Status != TransferPending {
+		return nil, ErrTransferClosed
+	}
+	if s.now().After(transfer.ExpiresAt) {
+		s.db.WithContext(ctx).Model(&transfer).Update("status", TransferExpired)
+		return nil, ErrTransferClosed
+	}
+	return &transfer, nil
+}
+
+func (s *Service) close(ctx context.Context, t *ProjectTransfer, status string, userID uint) error {
+	err := s.db.WithContext(ctx).Model(t).Updates(map[string]interface{}{
+		"status":       status,
+		"responded_by": userID,
+		"responded_at": s.now(),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("ownership: update transfer failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) checkOrgQuota(ctx context.Context, org *enterprise.Organization) error {
+	if org.MaxProjects <= 0 {
+		return nil
+	}
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Project{}).
+		Where("organization_id = ? AND (is_archived IS NULL OR is_archived = ?)", org.ID, false).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("ownership: count org projects failed: %w", err)
+	}
+	if count >= int64(org.MaxProjects) {
+		return ErrOrgProjectQuota
+	}
+	return nil
+}
+
+func (s *Service) loadProject(ctx context.Context, projectID uint) (*models.Project, error) {
+	var project models.Project
+	err := s.db.WithContext(ctx).First(&project, projectID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ownership: load project failed: %w", err)
+	}
+	return &project, nil
+}
+
+func (s *Service) loadOrg(ctx context.Context, orgID uint) (*enterprise.Organization, error) {
+	var org enterprise.Organization
+	err := s.db.WithContext(ctx).First(&org, orgID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrgNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ownership: load organization failed: %w", err)
+	}
+	return &org, nil
+}
+
+func sameOwner(project *models.Project, t *ProjectTransfer) bool {
+	if t.FromOrgID != nil {
+		return project.OrganizationID != nil && *project.OrganizationID == *t.FromOrgID
+	}
+	return project.OrganizationID == nil && t.FromUserID != nil && project.OwnerID == *t.FromUserID
+}
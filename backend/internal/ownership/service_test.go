@@ -0,0 +1,264 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakePermissions grants actions per "org:user" key; "manage" implies all.
+type fakePermissions map[string][]string
+
+func (f fakePermissions) HasPermission(orgID, userID uint, resource, action string) bool {
+	for _, a := range f[fmt.Sprintf("%d:%d", orgID, userID)] {
+		if a == action || a == "manage" {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeQuota struct{ allow bool }
+
+// fakeTokens records service token reassignments as "project:user".
+type fakeTokens struct{ reassigned []string }
+
+func (f *fakeTokens) Reassign(projectID, userID uint) error {
+	f.reassigned = append(f.reassigned, fmt.Sprintf("%d:%d", projectID, userID))
+	return nil
+}
+
+func (q *fakeQuota) AllowProject(ctx context.Context, userID uint) (bool, error) {
+	return q.allow, nil
+}
+
+type ownershipFixture struct {
+	svc   *Service
+	db    *gorm.DB
+	perms fakePermissions
+	alice models.User
+	bob   models.User
+	org   enterprise.Organization
+}
+
+func setupOwnershipTest(t *testing.T) *ownershipFixture {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.User{}, &models.Project{},
+		&enterprise.Organization{}, &enterprise.OrganizationMember{},
+		&ProjectTransfer{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	f := &ownershipFixture{db: db, perms: fakePermissions{}}
+	f.alice = models.User{Username: "alice", Email: "alice@example.com", PasswordHash: "x"}
+	f.bob = models.User{Username: "bob", Email: "bob@example.com", PasswordHash: "x"}
+	if err := db.Create(&f.alice).Error; err != nil {
+		t.Fatalf("create alice: %v", err)
+	}
+	if err := db.Create(&f.bob).Error; err != nil {
+		t.Fatalf("create bob: %v", err)
+	}
+	f.org = enterprise.Organization{Name: "Acme", Slug: "acme", MaxProjects: 2}
+	if err := db.Create(&f.org).Error; err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+	f.svc = NewService(db, f.perms)
+	return f
+}
+
+func (f *ownershipFixture) grant(orgID, userID uint, actions ...string) {
+	f.perms[fmt.Sprintf("%d:%d", orgID, userID)] = actions
+}
+
+func (f *ownershipFixture) userProject(t *testing.T, ownerID uint, name string) models.Project {
+	t.Helper()
+	project := models.Project{Name: name, Language: "go", OwnerID: ownerID}
+	if err := f.db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	return project
+}
+
+func TestCreateOrgProjectEnforcesPermissionAndOrgQuota(t *testing.T) {
+	f := setupOwnershipTest(t)
+	ctx := context.Background()
+
+	err := f.svc.CreateOrgProject(ctx, f.bob.ID, f.org.ID, &models.Project{Name: "nope", Language: "go"})
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden for non-member, got %v", err)
+	}
+
+	f.grant(f.org.ID, f.bob.ID, "create", "read")
+	for i := 0; i < 2; i++ {
+		project := &models.Project{Name: fmt.Sprintf("team-%d", i), Language: "go"}
+		if err := f.svc.CreateOrgProject(ctx, f.bob.ID, f.org.ID, project); err != nil {
+			t.Fatalf("create org project %d: %v", i, err)
+		}
+		if project.OrganizationID == nil || *project.OrganizationID != f.org.ID || project.OwnerID != f.bob.ID {
+			t.Fatalf("unexpected ownership on created project: %+v", project)
+		}
+	}
+	err = f.svc.CreateOrgProject(ctx, f.bob.ID, f.org.ID, &models.Project{Name: "team-3", Language: "go"})
+	if !errors.Is(err, ErrOrgProjectQuota) {
+		t.Fatalf("expected ErrOrgProjectQuota, got %v", err)
+	}
+
+	projects, err := f.svc.ListOrgProjects(ctx, f.bob.ID, f.org.ID)
+	if err != nil || len(projects) != 2 {
+		t.Fatalf("expected 2 org projects, got %d (%v)", len(projects), err)
+	}
+	readable, err := f.svc.ReadableOrgIDs(ctx, f.bob.ID)
+	if err != nil {
+		t.Fatalf("readable orgs: %v", err)
+	}
+	// Bob has permissions but no active membership row.
+	if len(readable) != 0 {
+		t.Fatalf("expected no readable orgs without membership, got %v", readable)
+	}
+}
+
+func TestTransferUserToOrgRequiresRecipientConfirmation(t *testing.T) {
+	f := setupOwnershipTest(t)
+	ctx := context.Background()
+	project := f.userProject(t, f.alice.ID, "solo")
+	f.grant(f.org.ID, f.bob.ID, "manage")
+
+	if _, err := f.svc.InitiateTransfer(ctx, f.bob.ID, project.ID, Target{OrganizationID: &f.org.ID}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden for non-owner, got %v", err)
+	}
+	transfer, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{OrganizationID: &f.org.ID})
+	if err != nil {
+		t.Fatalf("initiate transfer: %v", err)
+	}
+
+	// Alice has no manage permission in the org, so she cannot accept for it.
+	if _, err := f.svc.AcceptTransfer(ctx, f.alice.ID, transfer.ID); !errors.Is(err, ErrTransferNotFound) {
+		t.Fatalf("expected ErrTransferNotFound for initiator accept, got %v", err)
+	}
+	var unchanged models.Project
+	f.db.First(&unchanged, project.ID)
+	if unchanged.OrganizationID != nil {
+		t.Fatalf("project moved before confirmation")
+	}
+
+	accepted, err := f.svc.AcceptTransfer(ctx, f.bob.ID, transfer.ID)
+	if err != nil {
+		t.Fatalf("accept transfer: %v", err)
+	}
+	if accepted.Status != TransferAccepted {
+		t.Fatalf("expected accepted status, got %q", accepted.Status)
+	}
+	var moved models.Project
+	f.db.First(&moved, project.ID)
+	if moved.OrganizationID == nil || *moved.OrganizationID != f.org.ID {
+		t.Fatalf("expected project to belong to org, got %+v", moved.OrganizationID)
+	}
+	if _, err := f.svc.AcceptTransfer(ctx, f.bob.ID, transfer.ID); !errors.Is(err, ErrTransferClosed) {
+		t.Fatalf("expected ErrTransferClosed on second accept, got %v", err)
+	}
+}
+
+func TestTransferOrgToUserChecksRecipientQuota(t *testing.T) {
+	f := setupOwnershipTest(t)
+	ctx := context.Background()
+	f.grant(f.org.ID, f.alice.ID, "manage")
+	project := &models.Project{Name: "team", Language: "go"}
+	if err := f.svc.CreateOrgProject(ctx, f.alice.ID, f.org.ID, project); err != nil {
+		t.Fatalf("create org project: %v", err)
+	}
+
+	quota := &fakeQuota{allow: false}
+	f.svc.SetUserProjectQuota(quota)
+	tokens := &fakeTokens{}
+	f.svc.SetProjectTokens(tokens)
+	transfer, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{UserID: &f.bob.ID})
+	if err != nil {
+		t.Fatalf("initiate transfer: %v", err)
+	}
+	if _, err := f.svc.AcceptTransfer(ctx, f.bob.ID, transfer.ID); !errors.Is(err, ErrUserProjectQuota) {
+		t.Fatalf("expected ErrUserProjectQuota, got %v", err)
+	}
+
+	quota.allow = true
+	if _, err := f.svc.AcceptTransfer(ctx, f.bob.ID, transfer.ID); err != nil {
+		t.Fatalf("accept transfer: %v", err)
+	}
+	var moved models.Project
+	f.db.First(&moved, project.ID)
+	if moved.OrganizationID != nil || moved.OwnerID != f.bob.ID {
+		t.Fatalf("expected bob to own a personal project, got owner=%d org=%v", moved.OwnerID, moved.OrganizationID)
+	}
+	if want := fmt.Sprintf("%d:%d", project.ID, f.bob.ID); len(tokens.reassigned) != 1 || tokens.reassigned[0] != want {
+		t.Fatalf("expected the service token to move to bob, got %v", tokens.reassigned)
+	}
+}
+
+func TestTransferUserToUserDeclineCancelAndExpiry(t *testing.T) {
+	f := setupOwnershipTest(t)
+	ctx := context.Background()
+	project := f.userProject(t, f.alice.ID, "handoff")
+	now := time.Now().UTC()
+	f.svc.now = func() time.Time { return now }
+
+	if _, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{UserID: &f.alice.ID}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected ErrInvalidTarget for self transfer, got %v", err)
+	}
+	if _, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{UserID: &f.bob.ID, OrganizationID: &f.org.ID}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected ErrInvalidTarget for two targets, got %v", err)
+	}
+
+	first, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{UserID: &f.bob.ID})
+	if err != nil {
+		t.Fatalf("initiate transfer: %v", err)
+	}
+	if _, err := f.svc.DeclineTransfer(ctx, f.bob.ID, first.ID); err != nil {
+		t.Fatalf("decline transfer: %v", err)
+	}
+
+	second, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{UserID: &f.bob.ID})
+	if err != nil {
+		t.Fatalf("initiate second transfer: %v", err)
+	}
+	pending, err := f.svc.ListTransfers(ctx, f.bob.ID)
+	if err != nil || len(pending) != 1 || pending[0].ID != second.ID {
+		t.Fatalf("expected bob to see the second transfer, got %+v (%v)", pending, err)
+	}
+	if _, err := f.svc.CancelTransfer(ctx, f.bob.ID, second.ID); !errors.Is(err, ErrTransferNotFound) {
+		t.Fatalf("expected recipient cancel to be rejected, got %v", err)
+	}
+	if _, err := f.svc.CancelTransfer(ctx, f.alice.ID, second.ID); err != nil {
+		t.Fatalf("cancel transfer: %v", err)
+	}
+
+	third, err := f.svc.InitiateTransfer(ctx, f.alice.ID, project.ID, Target{UserID: &f.bob.ID})
+	if err != nil {
+		t.Fatalf("initiate third transfer: %v", err)
+	}
+	now = now.Add(TransferTTL + time.Minute)
+	if _, err := f.svc.AcceptTransfer(ctx, f.bob.ID, third.ID); !errors.Is(err, ErrTransferClosed) {
+		t.Fatalf("expected expired transfer to be closed, got %v", err)
+	}
+	var stored ProjectTransfer
+	f.db.First(&stored, third.ID)
+	if stored.Status != TransferExpired {
+		t.Fatalf("expected expired status, got %q", stored.Status)
+	}
+	var kept models.Project
+	f.db.First(&kept, project.ID)
+	if kept.OwnerID != f.alice.ID {
+		t.Fatalf("expected alice to keep the project, got owner %d", kept.OwnerID)
+	}
+}
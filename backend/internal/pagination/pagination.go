@@ -0,0 +1,322 @@
+// Package pagination implements the cursor pagination, sorting and sparse
+// fieldsets shared by list endpoints.
+//
+// Query parameters:
+//
+//	limit      page size, clamped to the endpoint's maximum
+//	cursor     opaque next_cursor from the previous page
+//	sort       one of the endpoint's sort keys; "-key" sorts descending
+//	order      asc or desc, overriding the sort key's default direction
+//	fields     comma-separated JSON fields to keep on each item
+//	page       legacy offset pagination, ignored when cursor is set
+//	page_size  legacy alias for limit
+//
+// Every list response carries a page_info object (Info) next to its items.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCursor is returned for cursors that don't decode or were
+	// issued for a different sort.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned for sort keys the endpoint doesn't offer.
+	ErrInvalidSort = errors.New("invalid sort")
+)
+
+// Sort is a sort option a list endpoint offers.
+type Sort struct {
+	Key    string // name accepted in ?sort=
+	Column string // SQL column or expression
+	Desc   bool   // default direction
+}
+
+// Spec describes an endpoint's pagination options.
+type Spec struct {
+	// DefaultLimit applies when ?limit is absent; 0 returns everything.
+	DefaultLimit int
+	MaxLimit     int
+	// Sorts lists the accepted sort keys; the first is the default.
+	Sorts []Sort
+	// IDColumn breaks ties between equal sort values; defaults to "id".
+	IDColumn string
+}
+
+// Request is a parsed list request.
+type Request struct {
+	Limit  int
+	Offset int
+	Sort   Sort
+	Desc   bool
+	Fields []string
+
+	idColumn string
+	cursor   *cursor
+}
+
+// Info is the page_info object returned with every page.
+type Info struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+}
+
+// Parse reads limit, cursor, sort, order, fields and page from the query.
+func Parse(c *gin.Context, spec Spec) (*Request, error) {
+	if len(spec.Sorts) == 0 {
+		return nil, fmt.Errorf("pagination: spec has no sorts")
+	}
+	r := &Request{Limit: spec.DefaultLimit, idColumn: spec.IDColumn}
+	if r.idColumn == "" {
+		r.idColumn = "id"
+	}
+
+	raw := c.Query("limit")
+	if raw == "" {
+		raw = c.Query("page_size")
+	}
+	if raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			r.Limit = n
+		}
+	}
+	if spec.MaxLimit > 0 && r.Limit > spec.MaxLimit {
+		r.Limit = spec.MaxLimit
+	}
+
+	key := strings.TrimSpace(c.Query("sort"))
+	forceDesc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+	r.Sort = spec.Sorts[0]
+	if key != "" {
+		found := false
+		for _, s := range spec.Sorts {
+			if s.Key == key {
+				r.Sort, found = s, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w %q: use one of %s", ErrInvalidSort, key, sortKeys(spec.Sorts))
+		}
+	}
+	r.Desc = r.Sort.Desc || forceDesc
+	switch strings.ToLower(c.Query("order")) {
+	case "asc":
+		r.Desc = false
+	case "desc":
+		r.Desc = true
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil || cur.Sort != r.Sort.Key || cur.Desc != r.Desc {
+			return nil, ErrInvalidCursor
+		}
+		r.cursor = cur
+	} else if raw := c.Query("page"); raw != "" && r.Limit > 0 {
+		if page, err := strconv.Atoi(raw); err == nil && page > 1 {
+			r.Offset = (page - 1) * r.Limit
+		}
+	}
+
+	if raw := c.Query("fields"); raw != "" {
+		seen := make(map[string]bool)
+		for _, f := range strings.Split(raw, ",") {
+			f = strings.TrimSpace(f)
+			if f != "" && !seen[f] {
+				seen[f] = true
+				r.Fields = append(r.Fields, f)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Apply adds ordering, the cursor condition and the limit to q. It fetches one
+// row beyond the limit so Page can tell whether another page exists.
+func (r *Request) Apply(q *gorm.DB) *gorm.DB {
+	dir, op := "ASC", ">"
+	if r.Desc {
+		dir, op = "DESC", "<"
+	}
+	if r.cursor != nil {
+		q = q.Where(
+			fmt.Sprintf("((%s %s ?) OR (%s = ? AND %s %s ?))", r.Sort.Column, op, r.Sort.Column, r.idColumn, op),
+			r.cursor.Value.value(), r.cursor.Value.value(), r.cursor.ID.value(),
+		)
+	}
+	q = q.Order(fmt.Sprintf("%s %s, %s %s", r.Sort.Column, dir, r.idColumn, dir))
+	if r.Limit > 0 {
+		q = q.Limit(r.Limit + 1)
+		if r.Offset > 0 {
+			q = q.Offset(r.Offset)
+		}
+	}
+	return q
+}
+
+// Page trims the extra row fetched by Apply and builds page_info. key returns
+// a row's sort value and ID, which become the next cursor.
+func Page[T any](r *Request, rows []T, key func(T) (value any, id any)) ([]T, Info) {
+	info := Info{Limit: r.Limit, Sort: r.Sort.Key, Order: "asc"}
+	if r.Desc {
+		info.Order = "desc"
+	}
+	if r.Limit > 0 && len(rows) > r.Limit {
+		rows = rows[:r.Limit]
+		info.HasMore = true
+		value, id := key(rows[len(rows)-1])
+		info.NextCursor = encodeCursor(&cursor{
+			Sort:  r.Sort.Key,
+			Desc:  r.Desc,
+			Value: newCursorValue(value),
+			ID:    newCursorValue(id),
+		})
+	}
+	return rows, info
+}
+
+// SelectFields keeps only the requested JSON fields on each item of a slice.
+// Unknown fields are ignored; with no fields the items are returned as-is.
+func SelectFields(items any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return items, nil
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("pagination: fields need a list of objects: %w", err)
+	}
+	out := make([]map[string]json.RawMessage, len(rows))
+	for i, row := range rows {
+		kept := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := row[f]; ok {
+				kept[f] = v
+			}
+		}
+		out[i] = kept
+	}
+	return out, nil
+}
+
+func sortKeys(sorts []Sort) string {
+	keys := make([]string, len(sorts))
+	for i, s := range sorts {
+		keys[i] = s.Key
+	}
+	return strings.Join(keys, ", ")
+}
+
+// cursor is the decoded form of next_cursor. It records the sort it was
+// issued for so it can't be replayed against a different ordering.
+type cursor struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d,omitempty"`
+	Value cursorValue `json:"v"`
+	ID    cursorValue `json:"i"`
+}
+
+// cursorValue keeps the Go type of a sort value across the round trip so
+// timestamps are compared as timestamps rather than strings.
+type cursorValue struct {
+	Kind  string  `json:"k"`
+	Str   string  `json:"s,omitempty"`
+	Int   int64   `json:"n,omitempty"`
+	Float float64 `json:"f,omitempty"`
+}
+
+func newCursorValue(v any) cursorValue {
+	switch x := v.(type) {
+	case time.Time:
+		return cursorValue{Kind: "t", Str: x.UTC().Format(time.RFC3339Nano)}
+	case *time.Time:
+		if x == nil {
+			return cursorValue{Kind: "t", Str: time.Time{}.Format(time.RFC3339Nano)}
+		}
+		return cursorValue{Kind: "t", Str: x.UTC().Format(time.RFC3339Nano)}
+	case string:
+		return cursorValue{Kind: "s", Str: x}
+	case int:
+		return cursorValue{Kind: "n", Int: int64(x)}
+	case int64:
+		return cursorValue{Kind: "n", Int: x}
+	case uint:
+		return cursorValue{Kind: "n", Int: int64(x)}
+	case uint64:
+		return cursorValue{Kind: "n", Int: int64(x)}
+	case float64:
+		return cursorValue{Kind: "f", Float: x}
+	case bool:
+		if x {
+			return cursorValue{Kind: "b", Int: 1}
+		}
+		return cursorValue{Kind: "b"}
+	default:
+		return cursorValue{Kind: "s", Str: fmt.Sprint(x)}
+	}
+}
+
+func (v cursorValue) value() any {
+	switch v.Kind {
+	case "t":
+		t, _ := time.Parse(time.RFC3339Nano, v.Str)
+		return t
+	case "n":
+		return v.Int
+	case "f":
+		return v.Float
+	case "b":
+		return v.Int == 1
+	default:
+		return v.Str
+	}
+}
+
+func (v cursorValue) valid() bool {
+	switch v.Kind {
+	case "t":
+		_, err := time.Parse(time.RFC3339Nano, v.Str)
+		return err == nil
+	case "s", "n", "f", "b":
+		return true
+	}
+	return false
+}
+
+func encodeCursor(c *cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if !c.Value.valid() || !c.ID.valid() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
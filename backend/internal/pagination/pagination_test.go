@@ -0,0 +1,163 @@
+package pagination
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Name      string    `json:"name"`
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var itemSpec = Spec{
+	DefaultLimit: 2,
+	MaxLimit:     3,
+	Sorts: []Sort{
+		{Key: "created_at", Column: "created_at", Desc: true},
+		{Key: "score", Column: "score", Desc: true},
+		{Key: "name", Column: "name"},
+	},
+}
+
+func testContext(query string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/items?"+query, nil)
+	return c
+}
+
+func testItemsDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&item{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	base := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	// Scores repeat so the ID tiebreaker is exercised.
+	for i, score := range []int{5, 3, 5, 1, 3} {
+		row := item{Name: string(rune('a' + i)), Score: score, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := db.Create(&row).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	return db
+}
+
+func collectPages(t *testing.T, db *gorm.DB, query string) []uint {
+	t.Helper()
+	var ids []uint
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		q := query
+		if cursor != "" {
+			q += "&cursor=" + cursor
+		}
+		list, err := Parse(testContext(q), itemSpec)
+		if err != nil {
+			t.Fatalf("parse %q: %v", q, err)
+		}
+		var rows []item
+		if err := list.Apply(db.Model(&item{})).Find(&rows).Error; err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		rows, info := Page(list, rows, func(r item) (any, any) {
+			switch list.Sort.Key {
+			case "score":
+				return r.Score, r.ID
+			case "name":
+				return r.Name, r.ID
+			}
+			return r.CreatedAt, r.ID
+		})
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+		}
+		if !info.HasMore {
+			return ids
+		}
+		cursor = info.NextCursor
+	}
+	t.Fatalf("pagination did not terminate")
+	return nil
+}
+
+func TestCursorPagesWalkEveryRowOnce(t *testing.T) {
+	db := testItemsDB(t)
+
+	cases := []struct {
+		query string
+		want  []uint
+	}{
+		{"", []uint{5, 4, 3, 2, 1}},
+		{"sort=score", []uint{3, 1, 5, 2, 4}},
+		{"sort=score&order=asc", []uint{4, 2, 5, 1, 3}},
+		{"sort=name&limit=3", []uint{1, 2, 3, 4, 5}},
+		{"sort=-name", []uint{5, 4, 3, 2, 1}},
+	}
+	for _, tc := range cases {
+		got := collectPages(t, db, tc.query)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%q: got %v, want %v", tc.query, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%q: got %v, want %v", tc.query, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestParseRejectsUnknownSortAndMismatchedCursor(t *testing.T) {
+	if _, err := Parse(testContext("sort=size"), itemSpec); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("expected ErrInvalidSort, got %v", err)
+	}
+	if _, err := Parse(testContext("cursor=not-a-cursor"), itemSpec); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	// A cursor issued for one sort can't be replayed against another.
+	list, _ := Parse(testContext("sort=score"), itemSpec)
+	_, info := Page(list, []item{{ID: 1, Score: 5}, {ID: 3, Score: 5}, {ID: 2, Score: 3}}, func(r item) (any, any) { return r.Score, r.ID })
+	if !info.HasMore || info.NextCursor == "" {
+		t.Fatalf("expected a next cursor, got %+v", info)
+	}
+	if _, err := Parse(testContext("sort=name&cursor="+info.NextCursor), itemSpec); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor for a different sort, got %v", err)
+	}
+
+	list, err := Parse(testContext("limit=50&page=2&page_size=1"), itemSpec)
+	if err != nil || list.Limit != 3 || list.Offset != 3 {
+		t.Fatalf("expected limit clamped to 3 with offset 3, got %+v (%v)", list, err)
+	}
+}
+
+func TestSelectFieldsKeepsRequestedKeys(t *testing.T) {
+	list, err := Parse(testContext("fields=id,name,,missing,name"), itemSpec)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(list.Fields) != 3 {
+		t.Fatalf("expected deduplicated fields, got %v", list.Fields)
+	}
+	selected, err := SelectFields([]item{{ID: 7, Name: "x", Score: 2}}, list.Fields)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	rows := selected.([]map[string]json.RawMessage)
+	if len(rows) != 1 || len(rows[0]) != 2 || string(rows[0]["id"]) != "7" || string(rows[0]["name"]) != `"x"` {
+		t.Fatalf("unexpected selection: %v", rows)
+	}
+}
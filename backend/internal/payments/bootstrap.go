@@ -12,7 +12,8 @@ import (
 )
 
 const (
-	CreditEntryTypeSignupTrial = "signup_trial"
+	CreditEntryTypeSignupTrial    = "signup_trial"
+	CreditEntryTypeReferralReward = "referral_reward"
 )
 
 func isDuplicateCreditInsertError(err error) bool {
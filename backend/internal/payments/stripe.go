@@ -234,6 +234,19 @@ func (s *StripeService) UpdateCustomer(ctx context.Context, customerID, email, n
 	}, nil
 }
 
+// DeleteCustomer permanently deletes a Stripe customer. Stripe cancels any
+// active subscriptions on the customer as part of the deletion.
+func (s *StripeService) DeleteCustomer(ctx context.Context, customerID string) error {
+	if !s.IsConfigured() {
+		return errors.New("stripe is not configured")
+	}
+
+	if _, err := customer.Del(customerID, nil); err != nil {
+		return fmt.Errorf("failed to delete customer: %w", err)
+	}
+	return nil
+}
+
 // CreateCheckoutSession creates a Stripe checkout session for subscription
 func (s *StripeService) CreateCheckoutSession(ctx context.Context, customerID, priceID, successURL, cancelURL string, metadata map[string]string, couponID ...string) (*CheckoutSessionResult, error) {
 	if !s.IsConfigured() {
@@ -0,0 +1,158 @@
+// Package pipeline promotes a project's builds through deployment
+// environments. Deploying snapshots the project's files into an immutable,
+// content-addressed artifact; promoting deploys that same artifact to the
+// next environment, so production runs exactly what passed smoke checks in
+// staging. Each environment deploys through a target: native .apex.app
+// hosting or one of the deploy providers.
+package pipeline
+
+import (
+	"errors"
+	"time"
+)
+
+// Environment names, in promotion order
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// environmentOrder is the promotion order; promote defaults to the previous
+// configured environment
+var environmentOrder = []string{EnvDevelopment, EnvStaging, EnvProduction}
+
+// TargetNative deploys to native .apex.app hosting. Any other target is a
+// deploy provider name such as "vercel".
+const TargetNative = "native"
+
+// Release statuses
+const (
+	ReleaseDeploying   = "deploying"
+	ReleaseSmokeTest   = "smoke_testing"
+	ReleaseLive        = "live"
+	ReleaseFailed      = "failed"
+	ReleaseSmokeFailed = "smoke_failed"
+	ReleaseSuperseded  = "superseded"
+)
+
+var (
+	ErrUnknownEnvironment = errors.New("unknown environment; use development, staging or production")
+	ErrEnvironmentMissing = errors.New("environment is not configured for this project")
+	ErrUnknownTarget      = errors.New("deployment target is not available")
+	ErrInvalidConfig      = errors.New("invalid environment config")
+	ErrReleaseInProgress  = errors.New("a release is already in progress for this environment")
+	ErrNothingToPromote   = errors.New("source environment has no live release that passed smoke checks")
+	ErrNoFiles            = errors.New("project has no files to deploy")
+)
+
+// Config is an environment's build and runtime settings. Unset values fall
+// back to the target's detection.
+type Config struct {
+	Framework       string `json:"framework,omitempty"`
+	BuildCommand    string `json:"build_command,omitempty"`
+	InstallCommand  string `json:"install_command,omitempty"`
+	StartCommand    string `json:"start_command,omitempty"`
+	OutputDir       string `json:"output_dir,omitempty"`
+	NodeVersion     string `json:"node_version,omitempty"`
+	Port            int    `json:"port,omitempty"`
+	HealthCheckPath string `json:"health_check_path,omitempty"`
+}
+
+// Environment is one stage of a project's pipeline
+type Environment struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint   `json:"project_id" gorm:"not null;uniqueIndex:idx_project_environment"`
+	Name      string `json:"name" gorm:"size:32;not null;uniqueIndex:idx_project_environment"`
+	Target    string `json:"target" gorm:"size:32;not null"`
+	Config    Config `json:"config" gorm:"serializer:json;type:text"`
+	// Paths requested after each deploy; every one must answer 2xx or 3xx
+	SmokePaths []string `json:"smoke_paths" gorm:"serializer:json;type:text"`
+
+	// Environment variables, encrypted with the project owner's key. Only
+	// the names are exposed.
+	EnvVarKeys       []string `json:"env_var_keys" gorm:"serializer:json;type:text"`
+	EnvVarsEncrypted string   `json:"-" gorm:"type:text"`
+	EnvVarsSalt      string   `json:"-" gorm:"size:64"`
+
+	LiveReleaseID *uint `json:"live_release_id,omitempty"`
+}
+
+// TableName specifies the table name for Environment
+func (Environment) TableName() string {
+	return "project_environments"
+}
+
+// ArtifactFile is one file in an artifact
+type ArtifactFile struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type,omitempty"`
+	IsDir    bool   `json:"is_dir,omitempty"`
+}
+
+// Artifact is an immutable snapshot of a project's files. Identical
+// snapshots share one artifact.
+type Artifact struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	ProjectID uint      `json:"project_id" gorm:"not null;uniqueIndex:idx_project_artifact_digest"`
+	// Digest is "sha256:" and the hash of every path and content in path order
+	Digest    string         `json:"digest" gorm:"size:80;not null;uniqueIndex:idx_project_artifact_digest"`
+	FileCount int            `json:"file_count"`
+	SizeBytes int64          `json:"size_bytes"`
+	CreatedBy uint           `json:"created_by"`
+	Files     []ArtifactFile `json:"-" gorm:"serializer:json;type:text"`
+}
+
+// TableName specifies the table name for Artifact
+func (Artifact) TableName() string {
+	return "build_artifacts"
+}
+
+// SmokeResult is the outcome of one smoke check request
+type SmokeResult struct {
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Passed     bool   `json:"passed"`
+}
+
+// Release is one deployment of an artifact to an environment
+type Release struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID      uint   `json:"project_id" gorm:"not null;index:idx_release_project_env"`
+	Environment    string `json:"environment" gorm:"size:32;not null;index:idx_release_project_env"`
+	ArtifactID     uint   `json:"artifact_id" gorm:"not null;index"`
+	ArtifactDigest string `json:"artifact_digest" gorm:"size:80"`
+	UserID         uint   `json:"user_id"`
+	Target         string `json:"target" gorm:"size:32"`
+	// DeploymentID is the native or provider deployment that serves it
+	DeploymentID string `json:"deployment_id,omitempty" gorm:"size:36;index"`
+	// PromotedFromID is the release in the source environment, if promoted
+	PromotedFromID *uint `json:"promoted_from_id,omitempty"`
+
+	Status       string        `json:"status" gorm:"size:20;not null;index"`
+	URL          string        `json:"url,omitempty"`
+	SmokeResults []SmokeResult `json:"smoke_results,omitempty" gorm:"serializer:json;type:text"`
+	Error        string        `json:"error,omitempty" gorm:"size:1000"`
+	CompletedAt  *time.Time    `json:"completed_at,omitempty"`
+}
+
+// TableName specifies the table name for Release
+func (Release) TableName() string {
+	return "environment_releases"
+}
+
+// InProgress reports whether the release is still deploying or testing
+func (r *Release) InProgress() bool {
+	return r.Status == ReleaseDeploying || r.Status == ReleaseSmokeTest
+}
@@ -0,0 +1,612 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxSmokePaths     = 10
+	maxEnvVars        = 100
+	defaultPoll       = 5 * time.Second
+	defaultTimeout    = 30 * time.Minute
+	smokeTimeout      = 10 * time.Second
+	smokeAttempts     = 3
+	defaultSmokeDelay = 5 * time.Second
+)
+
+var envVarKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+// EnvironmentSpec is the desired configuration of an environment. A nil
+// EnvVars keeps the current variables; an empty map clears them.
+type EnvironmentSpec struct {
+	Target     string            `json:"target"`
+	Config     Config            `json:"config"`
+	SmokePaths []string          `json:"smoke_paths"`
+	EnvVars    map[string]string `json:"env_vars"`
+}
+
+// EnvironmentView is an environment with the release it is serving and the
+// most recent release attempted on it
+type EnvironmentView struct {
+	Environment
+	LiveRelease   *Release `json:"live_release"`
+	LatestRelease *Release `json:"latest_release"`
+}
+
+// Service manages environments, artifacts and releases
+type Service struct {
+	db      *gorm.DB
+	secrets *secrets.SecretsManager
+
+	mu      sync.RWMutex
+	targets map[string]Target
+
+	smoke        *http.Client
+	pollInterval time.Duration
+	timeout      time.Duration
+	smokeDelay   time.Duration
+	now          func() time.Time
+}
+
+// NewService creates a pipeline service. Without a secrets manager
+// environments cannot hold environment variables.
+func NewService(db *gorm.DB, sm *secrets.SecretsManager) *Service {
+	return &Service{
+		db:      db,
+		secrets: sm,
+		targets: make(map[string]Target),
+		smoke: &http.Client{
+			Timeout: smokeTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		pollInterval: defaultPoll,
+		timeout:      defaultTimeout,
+		smokeDelay:   defaultSmokeDelay,
+		now:          time.Now,
+	}
+}
+
+// AutoMigrate creates the pipeline tables
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&Environment{}, &Artifact{}, &Release{})
+}
+
+// SetTarget registers a deployment target under a name
+func (s *Service) SetTarget(name string, target Target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets[name] = target
+}
+
+// Targets returns the registered target names
+func (s *Service) Targets() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.targets))
+	for name := range s.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Service) target(name string) (Target, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	target, ok := s.targets[name]
+	return target, ok
+}
+
+func validEnvironment(name string) bool {
+	for _, env := range environmentOrder {
+		if env == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) environment(projectID uint, name string) (*Environment, error) {
+	if !validEnvironment(name) {
+		return nil, ErrUnknownEnvironment
+	}
+	var env Environment
+	err := s.db.Where("project_id = ? AND name = ?", projectID, name).First(&env).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEnvironmentMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func (s *Service) release(id uint) (*Release, error) {
+	var release Release
+	if err := s.db.First(&release, id).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// ListEnvironments returns the project's environments in promotion order
+func (s *Service) ListEnvironments(projectID uint) ([]EnvironmentView, error) {
+	var envs []Environment
+	if err := s.db.Where("project_id = ?", projectID).Find(&envs).Error; err != nil {
+		return nil, err
+	}
+	rank := map[string]int{}
+	for i, name := range environmentOrder {
+		rank[name] = i
+	}
+	sort.Slice(envs, func(i, j int) bool { return rank[envs[i].Name] < rank[envs[j].Name] })
+
+	views := make([]EnvironmentView, len(envs))
+	for i, env := range envs {
+		views[i].Environment = env
+		if env.LiveReleaseID != nil {
+			views[i].LiveRelease, _ = s.release(*env.LiveReleaseID)
+		}
+		var latest Release
+		if err := s.db.Where("project_id = ? AND environment = ?", projectID, env.Name).
+			Order("id DESC").First(&latest).Error; err == nil {
+			views[i].LatestRelease = &latest
+		}
+	}
+	return views, nil
+}
+
+func normalizeSmokePaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return []string{"/"}, nil
+	}
+	if len(paths) > maxSmokePaths {
+		return nil, fmt.Errorf("%w: at most %d smoke paths", ErrInvalidConfig, maxSmokePaths)
+	}
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || len(path) > 200 ||
+			strings.ContainsAny(path, "@\\ \t\r\n") {
+			return nil, fmt.Errorf("%w: smoke path %q must be an absolute path such as /health", ErrInvalidConfig, path)
+		}
+		out = append(out, path)
+	}
+	return out, nil
+}
+
+// ApplyEnvironment creates or updates one of the project's environments
+func (s *Service) ApplyEnvironment(project *models.Project, name string, spec EnvironmentSpec) (*Environment, error) {
+	if !validEnvironment(name) {
+		return nil, ErrUnknownEnvironment
+	}
+	if _, ok := s.target(spec.Target); !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTarget, spec.Target)
+	}
+	if spec.Config.Port < 0 || spec.Config.Port > 65535 {
+		return nil, fmt.Errorf("%w: port must be 1-65535", ErrInvalidConfig)
+	}
+	smokePaths, err := normalizeSmokePaths(spec.SmokePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := s.environment(project.ID, name)
+	if errors.Is(err, ErrEnvironmentMissing) {
+		env = &Environment{ProjectID: project.ID, Name: name}
+	} else if err != nil {
+		return nil, err
+	}
+	env.Target = spec.Target
+	env.Config = spec.Config
+	env.SmokePaths = smokePaths
+
+	if spec.EnvVars != nil {
+		if len(spec.EnvVars) > maxEnvVars {
+			return nil, fmt.Errorf("%w: at most %d environment variables", ErrInvalidConfig, maxEnvVars)
+		}
+		keys := make([]string, 0, len(spec.EnvVars))
+		for key := range spec.EnvVars {
+			if !envVarKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("%w: invalid environment variable name %q", ErrInvalidConfig, key)
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		env.EnvVarKeys = keys
+		env.EnvVarsEncrypted, env.EnvVarsSalt = "", ""
+		if len(keys) > 0 {
+			if s.secrets == nil {
+				return nil, fmt.Errorf("%w: environment variables need the secrets manager", ErrInvalidConfig)
+			}
+			encrypted, salt, _, err := s.secrets.EncryptEnvVars(project.OwnerID, secrets.EnvironmentVariables(spec.EnvVars))
+			if err != nil {
+				return nil, fmt.Errorf("encrypt environment variables: %w", err)
+			}
+			env.EnvVarsEncrypted, env.EnvVarsSalt = encrypted, salt
+		}
+	}
+
+	if err := s.db.Save(env).Error; err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// DeleteEnvironment removes an environment. Its releases stay as history.
+func (s *Service) DeleteEnvironment(projectID uint, name string) error {
+	env, err := s.environment(projectID, name)
+	if err != nil {
+		return err
+	}
+	if s.inProgress(projectID, name) {
+		return ErrReleaseInProgress
+	}
+	return s.db.Delete(env).Error
+}
+
+// Releases returns an environment's releases, newest first
+func (s *Service) Releases(projectID uint, name string, limit int) ([]Release, error) {
+	if !validEnvironment(name) {
+		return nil, ErrUnknownEnvironment
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var releases []Release
+	err := s.db.Where("project_id = ? AND environment = ?", projectID, name).
+		Order("id DESC").Limit(limit).Find(&releases).Error
+	return releases, err
+}
+
+// Snapshot captures the project's current files as an artifact, reusing an
+// identical earlier snapshot
+func (s *Service) Snapshot(projectID, userID uint) (*Artifact, error) {
+	var files []models.File
+	if err := s.db.Select("path", "content", "size", "mime_type", "type").
+		Where("project_id = ?", projectID).Order("path").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+
+	artifact := &Artifact{ProjectID: projectID, CreatedBy: userID, FileCount: len(files)}
+	hash := sha256.New()
+	for _, f := range files {
+		artifact.Files = append(artifact.Files, ArtifactFile{
+			Path: f.Path, Content: f.Content, Size: f.Size, MimeType: f.MimeType, IsDir: f.Type == "directory",
+		})
+		artifact.SizeBytes += int64(len(f.Content))
+		hash.Write([]byte(f.Path))
+		hash.Write([]byte{0})
+		hash.Write([]byte(f.Content))
+		hash.Write([]byte{0})
+	}
+	artifact.Digest = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+
+	var existing Artifact
+	err := s.db.Select("id", "created_at", "project_id", "digest", "file_count", "size_bytes", "created_by").
+		Where("project_id = ? AND digest = ?", projectID, artifact.Digest).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := s.db.Create(artifact).Error; err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+func (s *Service) inProgress(projectID uint, name string) bool {
+	var count int64
+	s.db.Model(&Release{}).Where("project_id = ? AND environment = ? AND status IN ?",
+		projectID, name, []string{ReleaseDeploying, ReleaseSmokeTest}).Count(&count)
+	return count > 0
+}
+
+// Deploy snapshots the project's files and releases them to an environment
+func (s *Service) Deploy(ctx context.Context, project *models.Project, userID uint, name string) (*Release, error) {
+	env, err := s.environment(project.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	if s.inProgress(project.ID, name) {
+		return nil, ErrReleaseInProgress
+	}
+	artifact, err := s.Snapshot(project.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.startRelease(ctx, project, userID, env, artifact.ID, nil)
+}
+
+// Promote releases the artifact live in one environment to another. An
+// empty from promotes from the previous configured environment.
+func (s *Service) Promote(ctx context.Context, project *models.Project, userID uint, from, to string) (*Release, error) {
+	env, err := s.environment(project.ID, to)
+	if err != nil {
+		return nil, err
+	}
+	if from == "" {
+		from, err = s.previousEnvironment(project.ID, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: cannot promote an environment to itself", ErrInvalidConfig)
+	}
+	source, err := s.environment(project.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	if source.LiveReleaseID == nil {
+		return nil, ErrNothingToPromote
+	}
+	sourceRelease, err := s.release(*source.LiveReleaseID)
+	if err != nil || sourceRelease.Status != ReleaseLive {
+		return nil, ErrNothingToPromote
+	}
+	if s.inProgress(project.ID, to) {
+		return nil, ErrReleaseInProgress
+	}
+	return s.startRelease(ctx, project, userID, env, sourceRelease.ArtifactID, sourceRelease)
+}
+
+func (s *Service) previousEnvironment(projectID uint, to string) (string, error) {
+	var names []string
+	if err := s.db.Model(&Environment{}).Where("project_id = ?", projectID).Pluck("name", &names).Error; err != nil {
+		return "", err
+	}
+	previous := ""
+	for _, name := range environmentOrder {
+		if name == to {
+			break
+		}
+		for _, configured := range names {
+			if configured == name {
+				previous = name
+			}
+		}
+	}
+	if previous == "" {
+		return "", fmt.Errorf("%w: no environment before %s to promote from", ErrEnvironmentMissing, to)
+	}
+	return previous, nil
+}
+
+func (s *Service) envVars(ownerID uint, env *Environment) (map[string]string, error) {
+	if env.EnvVarsEncrypted == "" {
+		return nil, nil
+	}
+	if s.secrets == nil {
+		return nil, errors.New("secrets manager unavailable")
+	}
+	vars, err := s.secrets.DecryptEnvVars(ownerID, env.EnvVarsEncrypted, env.EnvVarsSalt)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt environment variables: %w", err)
+	}
+	return vars, nil
+}
+
+func (s *Service) startRelease(ctx context.Context, project *models.Project, userID uint, env *Environment, artifactID uint, from *Release) (*Release, error) {
+	target, ok := s.target(env.Target)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTarget, env.Target)
+	}
+	var artifact Artifact
+	if err := s.db.First(&artifact, artifactID).Error; err != nil {
+		return nil, fmt.Errorf("load artifact: %w", err)
+	}
+	vars, err := s.envVars(project.OwnerID, env)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{
+		ProjectID:      project.ID,
+		Environment:    env.Name,
+		ArtifactID:     artifact.ID,
+		ArtifactDigest: artifact.Digest,
+		UserID:         userID,
+		Target:         env.Target,
+		Status:         ReleaseDeploying,
+	}
+	if from != nil {
+		release.PromotedFromID = &from.ID
+	}
+	if err := s.db.Create(release).Error; err != nil {
+		return nil, err
+	}
+
+	deploymentID, err := target.Deploy(ctx, &DeployRequest{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		UserID:      userID,
+		Environment: env.Name,
+		Config:      env.Config,
+		EnvVars:     vars,
+		Files:       artifact.Files,
+	})
+	if err != nil {
+		s.finish(release, ReleaseFailed, err.Error())
+		return release, nil
+	}
+	release.DeploymentID = deploymentID
+	s.db.Model(release).Update("deployment_id", deploymentID)
+	go s.watch(release.ID)
+	return release, nil
+}
+
+// Resume watches releases left in progress by a restart
+func (s *Service) Resume() {
+	var releases []Release
+	s.db.Where("status IN ?", []string{ReleaseDeploying, ReleaseSmokeTest}).Find(&releases)
+	for i := range releases {
+		if releases[i].DeploymentID == "" {
+			s.finish(&releases[i], ReleaseFailed, "interrupted before the deployment started")
+			continue
+		}
+		go s.watch(releases[i].ID)
+	}
+}
+
+func (s *Service) watch(releaseID uint) {
+	release, err := s.release(releaseID)
+	if err != nil {
+		return
+	}
+	target, ok := s.target(release.Target)
+	if !ok {
+		s.finish(release, ReleaseFailed, "deployment target is no longer available")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := target.Status(ctx, release.DeploymentID)
+		if err != nil {
+			log.Printf("[pipeline] release %d: status check failed: %v", release.ID, err)
+		} else {
+			switch status.State {
+			case ReleaseFailed:
+				s.finish(release, ReleaseFailed, status.Error)
+				return
+			case ReleaseLive:
+				s.smokeTest(ctx, release, status.URL)
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			s.finish(release, ReleaseFailed, "timed out waiting for the deployment")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) smokeTest(ctx context.Context, release *Release, url string) {
+	release.URL = url
+	release.Status = ReleaseSmokeTest
+	s.db.Model(release).Updates(map[string]interface{}{"url": url, "status": ReleaseSmokeTest})
+
+	env, err := s.environment(release.ProjectID, release.Environment)
+	paths := []string{"/"}
+	if err == nil && len(env.SmokePaths) > 0 {
+		paths = env.SmokePaths
+	}
+	if url == "" {
+		s.finish(release, ReleaseSmokeFailed, "the deployment did not report a URL")
+		return
+	}
+
+	passed := true
+	results := make([]SmokeResult, 0, len(paths))
+	for _, path := range paths {
+		result := s.check(ctx, strings.TrimRight(url, "/")+path)
+		result.Path = path
+		results = append(results, result)
+		passed = passed && result.Passed
+	}
+	release.SmokeResults = results
+	s.db.Model(release).Select("smoke_results").Updates(&Release{SmokeResults: results})
+	if !passed {
+		s.finish(release, ReleaseSmokeFailed, "smoke checks failed")
+		return
+	}
+	s.finish(release, ReleaseLive, "")
+}
+
+// check requests a URL until it answers below 400, a few times at most
+// since a fresh deployment may still be warming up
+func (s *Service) check(ctx context.Context, url string) SmokeResult {
+	var result SmokeResult
+	for attempt := 0; attempt < smokeAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				result.Error = ctx.Err().Error()
+				return result
+			case <-time.After(s.smokeDelay):
+			}
+		}
+		start := s.now()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		req.Header.Set("User-Agent", "apex-build-smoke-check")
+		resp, err := s.smoke.Do(req)
+		result.DurationMs = s.now().Sub(start).Milliseconds()
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		resp.Body.Close()
+		result.StatusCode = resp.StatusCode
+		result.Error = ""
+		if resp.StatusCode < 400 {
+			result.Passed = true
+			return result
+		}
+		result.Error = fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	return result
+}
+
+// finish records a release's outcome. A live release becomes the one its
+// environment serves and supersedes the previous one.
+func (s *Service) finish(release *Release, status, message string) {
+	now := s.now()
+	release.Status = status
+	release.Error = message
+	release.CompletedAt = &now
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(release).Updates(map[string]interface{}{
+			"status": status, "error": message, "completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if status != ReleaseLive {
+			return nil
+		}
+		if err := tx.Model(&Release{}).
+			Where("project_id = ? AND environment = ? AND status = ? AND id <> ?",
+				release.ProjectID, release.Environment, ReleaseLive, release.ID).
+			Update("status", ReleaseSuperseded).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Environment{}).
+			Where("project_id = ? AND name = ?", release.ProjectID, release.Environment).
+			Update("live_release_id", release.ID).Error
+	})
+	if err != nil {
+		log.Printf("[pipeline] release %d: record %s: %v", release.ID, status, err)
+	}
+}
@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeTarget goes live immediately at url and remembers what it deployed
+type fakeTarget struct {
+	mu       sync.Mutex
+	url      string
+	deployed []*DeployRequest
+}
+
+func (f *fakeTarget) Deploy(_ context.Context, req *DeployRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deployed = append(f.deployed, req)
+	return "dep-" + req.Environment, nil
+}
+
+func (f *fakeTarget) Status(context.Context, string) (*TargetStatus, error) {
+	return &TargetStatus{State: ReleaseLive, URL: f.url}, nil
+}
+
+func (f *fakeTarget) last() *DeployRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.deployed[len(f.deployed)-1]
+}
+
+func setupPipelineTest(t *testing.T) (*Service, *gorm.DB, *models.Project, *fakeTarget, *bool) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	sm, err := secrets.NewSecretsManager("pipeline-test-master-key-0123456789abcdef")
+	if err != nil {
+		t.Fatalf("secrets: %v", err)
+	}
+	service := NewService(db, sm)
+	if err := service.AutoMigrate(); err != nil {
+		t.Fatalf("migrate pipeline: %v", err)
+	}
+	service.pollInterval = 5 * time.Millisecond
+	service.smokeDelay = time.Millisecond
+
+	healthy := true
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy && r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(app.Close)
+	target := &fakeTarget{url: app.URL}
+	service.SetTarget(TargetNative, target)
+
+	user := &models.User{Username: "ada", Email: "ada@example.com", PasswordHash: "x"}
+	db.Create(user)
+	project := &models.Project{Name: "shop", OwnerID: user.ID, Language: "javascript"}
+	if err := db.Create(project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	db.Create(&models.File{ProjectID: project.ID, Path: "index.js", Name: "index.js", Type: "file", Content: "v1"})
+	return service, db, project, target, &healthy
+}
+
+func waitForRelease(t *testing.T, s *Service, id uint) *Release {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		release, err := s.release(id)
+		if err == nil && !release.InProgress() {
+			return release
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("release %d did not finish", id)
+	return nil
+}
+
+func TestPromoteDeploysTheSameArtifact(t *testing.T) {
+	service, db, project, target, _ := setupPipelineTest(t)
+	staging := EnvironmentSpec{Target: TargetNative, SmokePaths: []string{"/", "/health"}, EnvVars: map[string]string{"API_URL": "https://staging.example"}}
+	if _, err := service.ApplyEnvironment(project, EnvStaging, staging); err != nil {
+		t.Fatalf("apply staging: %v", err)
+	}
+	prod, err := service.ApplyEnvironment(project, EnvProduction, EnvironmentSpec{Target: TargetNative, EnvVars: map[string]string{"API_URL": "https://api.example"}})
+	if err != nil || prod.EnvVarsEncrypted == "" || len(prod.EnvVarKeys) != 1 {
+		t.Fatalf("apply production = %+v %v", prod, err)
+	}
+
+	release, err := service.Deploy(context.Background(), project, project.OwnerID, EnvStaging)
+	if err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+	release = waitForRelease(t, service, release.ID)
+	if release.Status != ReleaseLive || len(release.SmokeResults) != 2 {
+		t.Fatalf("staging release = %+v", release)
+	}
+
+	// Files changed after staging must not leak into the promotion
+	db.Model(&models.File{}).Where("project_id = ?", project.ID).Update("content", "v2")
+	promoted, err := service.Promote(context.Background(), project, project.OwnerID, "", EnvProduction)
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	promoted = waitForRelease(t, service, promoted.ID)
+	if promoted.Status != ReleaseLive || promoted.ArtifactID != release.ArtifactID ||
+		promoted.PromotedFromID == nil || *promoted.PromotedFromID != release.ID {
+		t.Fatalf("promoted release = %+v, staging = %+v", promoted, release)
+	}
+	req := target.last()
+	if req.Files[0].Content != "v1" || req.EnvVars["API_URL"] != "https://api.example" {
+		t.Fatalf("promotion deployed %+v with env %v", req.Files, req.EnvVars)
+	}
+
+	views, _ := service.ListEnvironments(project.ID)
+	if len(views) != 2 || views[0].Name != EnvStaging || views[1].LiveRelease == nil || views[1].LiveRelease.ID != promoted.ID {
+		t.Fatalf("environments = %+v", views)
+	}
+
+	// A fresh deploy snapshots the new files and supersedes the old release
+	again, _ := service.Deploy(context.Background(), project, project.OwnerID, EnvStaging)
+	again = waitForRelease(t, service, again.ID)
+	if again.ArtifactID == release.ArtifactID {
+		t.Fatal("changed files reused the old artifact")
+	}
+	if old, _ := service.release(release.ID); old.Status != ReleaseSuperseded {
+		t.Fatalf("previous staging release status = %s", old.Status)
+	}
+}
+
+func TestFailedSmokeChecksBlockPromotion(t *testing.T) {
+	service, _, project, _, healthy := setupPipelineTest(t)
+	service.ApplyEnvironment(project, EnvStaging, EnvironmentSpec{Target: TargetNative, SmokePaths: []string{"/health"}})
+	service.ApplyEnvironment(project, EnvProduction, EnvironmentSpec{Target: TargetNative})
+
+	*healthy = false
+	release, err := service.Deploy(context.Background(), project, project.OwnerID, EnvStaging)
+	if err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+	release = waitForRelease(t, service, release.ID)
+	if release.Status != ReleaseSmokeFailed || release.SmokeResults[0].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("release = %+v", release)
+	}
+	if _, err := service.Promote(context.Background(), project, project.OwnerID, EnvStaging, EnvProduction); !errors.Is(err, ErrNothingToPromote) {
+		t.Fatalf("promote err = %v", err)
+	}
+}
+
+func TestApplyEnvironmentValidates(t *testing.T) {
+	service, _, project, _, _ := setupPipelineTest(t)
+	cases := []struct {
+		name string
+		env  string
+		spec EnvironmentSpec
+		want error
+	}{
+		{"unknown environment", "qa", EnvironmentSpec{Target: TargetNative}, ErrUnknownEnvironment},
+		{"unknown target", EnvStaging, EnvironmentSpec{Target: "heroku"}, ErrUnknownTarget},
+		{"relative smoke path", EnvStaging, EnvironmentSpec{Target: TargetNative, SmokePaths: []string{"health"}}, ErrInvalidConfig},
+		{"host in smoke path", EnvStaging, EnvironmentSpec{Target: TargetNative, SmokePaths: []string{"//evil.example/"}}, ErrInvalidConfig},
+		{"bad env var name", EnvStaging, EnvironmentSpec{Target: TargetNative, EnvVars: map[string]string{"1BAD": "x"}}, ErrInvalidConfig},
+	}
+	for _, tc := range cases {
+		if _, err := service.ApplyEnvironment(project, tc.env, tc.spec); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if _, err := service.Deploy(context.Background(), project, project.OwnerID, EnvProduction); !errors.Is(err, ErrEnvironmentMissing) {
+		t.Errorf("deploy to unconfigured environment err = %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"context"
+
+	"apex-build/internal/deploy"
+	"apex-build/internal/hosting"
+)
+
+// DeployRequest is an artifact deployment handed to a target
+type DeployRequest struct {
+	ProjectID   uint
+	ProjectName string
+	UserID      uint
+	Environment string
+	Config      Config
+	EnvVars     map[string]string
+	Files       []ArtifactFile
+}
+
+// TargetStatus is a target deployment's progress. State is
+// ReleaseDeploying, ReleaseLive or ReleaseFailed.
+type TargetStatus struct {
+	State string
+	URL   string
+	Error string
+}
+
+// Target deploys artifacts somewhere and reports on the deployment
+type Target interface {
+	Deploy(ctx context.Context, req *DeployRequest) (deploymentID string, err error)
+	Status(ctx context.Context, deploymentID string) (*TargetStatus, error)
+}
+
+// ProviderTarget deploys through a deploy provider such as Vercel
+type ProviderTarget struct {
+	service  *deploy.DeploymentService
+	provider deploy.DeploymentProvider
+}
+
+// NewProviderTarget creates a target for a registered deploy provider
+func NewProviderTarget(service *deploy.DeploymentService, provider deploy.DeploymentProvider) *ProviderTarget {
+	return &ProviderTarget{service: service, provider: provider}
+}
+
+// Deploy starts a provider deployment pinned to the artifact's files
+func (t *ProviderTarget) Deploy(ctx context.Context, req *DeployRequest) (string, error) {
+	files := make([]deploy.ProjectFile, len(req.Files))
+	for i, f := range req.Files {
+		files[i] = deploy.ProjectFile{Path: f.Path, Content: f.Content, Size: f.Size, MimeType: f.MimeType, IsDir: f.IsDir}
+	}
+	deployment, err := t.service.StartDeployment(ctx, req.UserID, &deploy.DeploymentConfig{
+		ProjectID:    req.ProjectID,
+		Provider:     t.provider,
+		Environment:  req.Environment,
+		Branch:       "main",
+		EnvVars:      req.EnvVars,
+		BuildCommand: req.Config.BuildCommand,
+		OutputDir:    req.Config.OutputDir,
+		InstallCmd:   req.Config.InstallCommand,
+		StartCommand: req.Config.StartCommand,
+		Framework:    req.Config.Framework,
+		NodeVersion:  req.Config.NodeVersion,
+		Files:        files,
+	})
+	if err != nil {
+		return "", err
+	}
+	return deployment.ID, nil
+}
+
+// Status maps the provider deployment's status
+func (t *ProviderTarget) Status(_ context.Context, deploymentID string) (*TargetStatus, error) {
+	deployment, err := t.service.GetDeploymentStatus(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch deployment.Status {
+	case deploy.StatusLive:
+		return &TargetStatus{State: ReleaseLive, URL: deployment.URL}, nil
+	case deploy.StatusFailed, deploy.StatusCancelled:
+		return &TargetStatus{State: ReleaseFailed, Error: firstNonEmpty(deployment.ErrorMessage, "deployment "+string(deployment.Status))}, nil
+	}
+	return &TargetStatus{State: ReleaseDeploying}, nil
+}
+
+// NativeTarget deploys to native .apex.app hosting
+type NativeTarget struct {
+	service *hosting.HostingService
+}
+
+// NewNativeTarget creates a native hosting target
+func NewNativeTarget(service *hosting.HostingService) *NativeTarget {
+	return &NativeTarget{service: service}
+}
+
+// Deploy starts a native deployment of the artifact's files
+func (t *NativeTarget) Deploy(ctx context.Context, req *DeployRequest) (string, error) {
+	files := make([]hosting.ProjectFile, len(req.Files))
+	for i, f := range req.Files {
+		files[i] = hosting.ProjectFile{Path: f.Path, Content: f.Content, Size: f.Size, IsDir: f.IsDir}
+	}
+	deployment, err := t.service.StartDeployment(ctx, req.ProjectID, req.UserID, &hosting.DeploymentConfig{
+		ProjectName:     req.ProjectName + "-" + req.Environment,
+		Port:            req.Config.Port,
+		BuildCommand:    req.Config.BuildCommand,
+		StartCommand:    req.Config.StartCommand,
+		InstallCommand:  req.Config.InstallCommand,
+		Framework:       req.Config.Framework,
+		NodeVersion:     req.Config.NodeVersion,
+		HealthCheckPath: req.Config.HealthCheckPath,
+		EnvVars:         req.EnvVars,
+		Files:           files,
+	})
+	if err != nil {
+		return "", err
+	}
+	return deployment.ID, nil
+}
+
+// Status maps the native deployment's status
+func (t *NativeTarget) Status(_ context.Context, deploymentID string) (*TargetStatus, error) {
+	deployment, err := t.service.GetDeployment(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch deployment.Status {
+	case hosting.StatusRunning:
+		return &TargetStatus{State: ReleaseLive, URL: deployment.GetFullURL()}, nil
+	case hosting.StatusFailed, hosting.StatusStopped, hosting.StatusDeleted:
+		return &TargetStatus{State: ReleaseFailed, Error: firstNonEmpty(deployment.ErrorMessage, "deployment "+string(deployment.Status))}, nil
+	}
+	return &TargetStatus{State: ReleaseDeploying}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+)
+
+var (
+	blobStoreMu sync.RWMutex
+	blobStore   storage.Provider
+)
+
+// SetBlobStore wires the blob store that binary project files (images, fonts)
+// are read from when previews load or write project files.
+func SetBlobStore(p storage.Provider) {
+	blobStoreMu.Lock()
+	defer blobStoreMu.Unlock()
+	blobStore = p
+}
+
+// fileBytes returns a project file's bytes, reading binary files from the
+// blob store.
+func fileBytes(ctx context.Context, file *models.File) ([]byte, error) {
+	if !file.IsBinary {
+		return []byte(file.Content), nil
+	}
+	blobStoreMu.RLock()
+	store := blobStore
+	blobStoreMu.RUnlock()
+	if store == nil {
+		return nil, fmt.Errorf("no blob store configured for binary file %s", file.Path)
+	}
+	return storage.ReadBlob(ctx, store, file.Hash)
+}
@@ -475,7 +475,12 @@ func (s *ContainerPreviewServer) StartContainerPreview(ctx context.Context, conf
 			os.RemoveAll(tempDir)
 			return nil, fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
 		}
-		if err := os.WriteFile(filePath, []byte(file.Content), 0644); err != nil {
+		content, err := fileBytes(ctx, &file)
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return nil, fmt.Errorf("failed to read file %s: %w", file.Path, err)
+		}
+		if err := os.WriteFile(filePath, content, 0644); err != nil {
 			os.RemoveAll(tempDir)
 			return nil, fmt.Errorf("failed to write file %s: %w", file.Path, err)
 		}
@@ -1603,7 +1608,9 @@ func (s *ContainerPreviewServer) RefreshContainerPreview(ctx context.Context, pr
 		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 			continue
 		}
-		os.WriteFile(filePath, []byte(file.Content), 0644)
+		if content, err := fileBytes(ctx, &file); err == nil {
+			os.WriteFile(filePath, content, 0644)
+		}
 	}
 
 	// For now, we'll need to restart the container to pick up changes
@@ -0,0 +1,394 @@
+// Package preview - Runtime diagnostics reported by preview pages
+package preview
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/bundler"
+)
+
+// Diagnostic kinds
+const (
+	// DiagnosticKindError is an uncaught error
+	DiagnosticKindError = "error"
+	// DiagnosticKindRejection is an unhandled promise rejection
+	DiagnosticKindRejection = "unhandledrejection"
+	// DiagnosticKindConsole is a console.error or console.warn call
+	DiagnosticKindConsole = "console"
+)
+
+const (
+	// maxDiagnosticsPerProject is how many diagnostics are kept per project
+	maxDiagnosticsPerProject = 200
+	// maxDiagnosticsBatch is how many reports one request may carry
+	maxDiagnosticsBatch = 50
+	// maxDiagnosticsBody caps the size of a report request
+	maxDiagnosticsBody = 256 << 10
+	// maxDiagnosticMessage and maxDiagnosticStack cap stored text
+	maxDiagnosticMessage = 4 << 10
+	maxDiagnosticStack   = 8 << 10
+	// maxDiagnosticFrames caps the parsed frames of one stack
+	maxDiagnosticFrames = 50
+)
+
+// ErrInvalidDiagnostics is returned for report bodies that cannot be decoded
+var ErrInvalidDiagnostics = errors.New("invalid diagnostics report")
+
+// DiagnosticReport is what the injected preview script posts for one
+// error or console message
+type DiagnosticReport struct {
+	Kind     string `json:"kind"`
+	Level    string `json:"level"`
+	Message  string `json:"message"`
+	Stack    string `json:"stack"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// DiagnosticFrame is one stack frame, newest call first as browsers report
+// them. Original is set when the frame was mapped through a source map.
+type DiagnosticFrame struct {
+	Function string            `json:"function,omitempty"`
+	File     string            `json:"file"`
+	Line     int               `json:"line"`
+	Column   int               `json:"column"`
+	Original *OriginalPosition `json:"original,omitempty"`
+}
+
+// Location returns the frame's original position when known, or its
+// position in the served file
+func (f DiagnosticFrame) Location() (file string, line, column int) {
+	if f.Original != nil {
+		return f.Original.Source, f.Original.Line, f.Original.Column
+	}
+	return strings.TrimPrefix(f.File, "/"), f.Line, f.Column
+}
+
+// Diagnostic is a runtime error or warning captured from a preview.
+// Repeats of the same message and location are collapsed into one entry.
+type Diagnostic struct {
+	ID        uint64            `json:"id"`
+	Kind      string            `json:"kind"`
+	Level     string            `json:"level"`
+	Message   string            `json:"message"`
+	Stack     string            `json:"stack,omitempty"`
+	Frames    []DiagnosticFrame `json:"frames,omitempty"`
+	Source    string            `json:"source,omitempty"`
+	PageURL   string            `json:"page_url,omitempty"`
+	Count     int               `json:"count"`
+	FirstSeen time.Time         `json:"first_seen"`
+	LastSeen  time.Time         `json:"last_seen"`
+}
+
+// DiagnosticsStore keeps the latest diagnostics of each project in memory
+type DiagnosticsStore struct {
+	// mu protects the store
+	mu sync.Mutex
+	// nextID numbers diagnostics across projects
+	nextID uint64
+	// entries holds each project's diagnostics, oldest first
+	entries map[uint][]Diagnostic
+	// maps caches each project's decoded bundle source map by bundle hash
+	maps map[uint]cachedSourceMap
+}
+
+// cachedSourceMap is a decoded source map and the bundle it belongs to
+type cachedSourceMap struct {
+	hash string
+	m    *sourceMap
+}
+
+// NewDiagnosticsStore creates an empty diagnostics store
+func NewDiagnosticsStore() *DiagnosticsStore {
+	return &DiagnosticsStore{
+		entries: make(map[uint][]Diagnostic),
+		maps:    make(map[uint]cachedSourceMap),
+	}
+}
+
+// DecodeDiagnosticReports reads a report body, which is a JSON array of
+// reports or an object with a "reports" array
+func DecodeDiagnosticReports(r io.Reader) ([]DiagnosticReport, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxDiagnosticsBody+1))
+	if err != nil || len(body) > maxDiagnosticsBody {
+		return nil, ErrInvalidDiagnostics
+	}
+	var reports []DiagnosticReport
+	if err := json.Unmarshal(body, &reports); err != nil {
+		var wrapped struct {
+			Reports []DiagnosticReport `json:"reports"`
+		}
+		if json.Unmarshal(body, &wrapped) != nil {
+			return nil, ErrInvalidDiagnostics
+		}
+		reports = wrapped.Reports
+	}
+	if len(reports) > maxDiagnosticsBatch {
+		reports = reports[:maxDiagnosticsBatch]
+	}
+	return reports, nil
+}
+
+// Record stores reports for a project, mapping frames in the bundle through
+// its source map, and returns the stored diagnostics
+func (s *DiagnosticsStore) Record(projectID uint, reports []DiagnosticReport, bundle *bundler.BundleResult, now time.Time) []Diagnostic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm := s.sourceMapLocked(projectID, bundle)
+	recorded := make([]Diagnostic, 0, len(reports))
+	for _, report := range reports {
+		diagnostic := newDiagnostic(report, sm, now)
+		if diagnostic.Message == "" {
+			continue
+		}
+		recorded = append(recorded, s.addLocked(projectID, diagnostic))
+	}
+	return recorded
+}
+
+// addLocked stores a diagnostic, or counts it against an earlier one with
+// the same message and location (must hold s.mu)
+func (s *DiagnosticsStore) addLocked(projectID uint, diagnostic Diagnostic) Diagnostic {
+	entries := s.entries[projectID]
+	for i := range entries {
+		existing := &entries[i]
+		if existing.Kind == diagnostic.Kind && existing.Level == diagnostic.Level &&
+			existing.Message == diagnostic.Message && existing.Source == diagnostic.Source {
+			existing.Count++
+			existing.LastSeen = diagnostic.LastSeen
+			return *existing
+		}
+	}
+	s.nextID++
+	diagnostic.ID = s.nextID
+	entries = append(entries, diagnostic)
+	if len(entries) > maxDiagnosticsPerProject {
+		entries = entries[len(entries)-maxDiagnosticsPerProject:]
+	}
+	s.entries[projectID] = entries
+	return diagnostic
+}
+
+// sourceMapLocked returns the decoded source map of a bundle (must hold s.mu)
+func (s *DiagnosticsStore) sourceMapLocked(projectID uint, bundle *bundler.BundleResult) *sourceMap {
+	if bundle == nil || len(bundle.SourceMap) == 0 {
+		return nil
+	}
+	if cached, ok := s.maps[projectID]; ok && cached.hash == bundle.Hash {
+		return cached.m
+	}
+	sm, err := parseSourceMap(bundle.SourceMap)
+	if err != nil {
+		sm = nil
+	}
+	s.maps[projectID] = cachedSourceMap{hash: bundle.Hash, m: sm}
+	return sm
+}
+
+// List returns a project's diagnostics with IDs above sinceID, oldest first
+func (s *DiagnosticsStore) List(projectID uint, sinceID uint64) []Diagnostic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	diagnostics := make([]Diagnostic, 0)
+	for _, diagnostic := range s.entries[projectID] {
+		if diagnostic.ID > sinceID {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics
+}
+
+// Get returns one of a project's diagnostics
+func (s *DiagnosticsStore) Get(projectID uint, id uint64) (Diagnostic, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, diagnostic := range s.entries[projectID] {
+		if diagnostic.ID == id {
+			return diagnostic, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+// Clear drops a project's diagnostics
+func (s *DiagnosticsStore) Clear(projectID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, projectID)
+	delete(s.maps, projectID)
+}
+
+// newDiagnostic normalizes a report and resolves its stack
+func newDiagnostic(report DiagnosticReport, sm *sourceMap, now time.Time) Diagnostic {
+	diagnostic := Diagnostic{
+		Kind:      report.Kind,
+		Level:     report.Level,
+		Message:   truncateDiagnostic(strings.TrimSpace(report.Message), maxDiagnosticMessage),
+		Stack:     truncateDiagnostic(report.Stack, maxDiagnosticStack),
+		PageURL:   truncateDiagnostic(report.URL, 2048),
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	switch diagnostic.Kind {
+	case DiagnosticKindError, DiagnosticKindRejection:
+		diagnostic.Level = "error"
+	default:
+		diagnostic.Kind = DiagnosticKindConsole
+		if diagnostic.Level != "warn" {
+			diagnostic.Level = "error"
+		}
+	}
+
+	diagnostic.Frames = parseStackFrames(diagnostic.Stack)
+	if len(diagnostic.Frames) == 0 && report.Filename != "" && report.Line > 0 {
+		diagnostic.Frames = []DiagnosticFrame{{File: framePath(report.Filename), Line: report.Line, Column: report.Column}}
+	}
+	for i := range diagnostic.Frames {
+		frame := &diagnostic.Frames[i]
+		if sm != nil && isBundleFrame(frame.File) {
+			if pos, ok := sm.lookup(frame.Line, frame.Column); ok {
+				frame.Original = &pos
+			}
+		}
+	}
+	for _, frame := range diagnostic.Frames {
+		if frame.Original != nil || !isBundleFrame(frame.File) {
+			file, line, column := frame.Location()
+			if file != "" && !strings.Contains(file, "node_modules/") {
+				diagnostic.Source = fmt.Sprintf("%s:%d:%d", file, line, column)
+				break
+			}
+		}
+	}
+	return diagnostic
+}
+
+var (
+	// chromeFramePattern matches "at fn (file:line:col)" and "at file:line:col"
+	chromeFramePattern = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+?):(\d+):(\d+)\)?$`)
+	// firefoxFramePattern matches "fn@file:line:col", as Firefox and Safari write
+	firefoxFramePattern = regexp.MustCompile(`^\s*(?:([^@]*)@)?(.+?):(\d+):(\d+)$`)
+	// previewProxyPrefix is the path the preview proxy serves pages under
+	previewProxyPrefix = regexp.MustCompile(`^/api/v1/preview/proxy/\d+`)
+)
+
+// parseStackFrames parses a browser stack trace
+func parseStackFrames(stack string) []DiagnosticFrame {
+	var frames []DiagnosticFrame
+	for _, line := range strings.Split(stack, "\n") {
+		matches := chromeFramePattern.FindStringSubmatch(line)
+		if matches == nil {
+			matches = firefoxFramePattern.FindStringSubmatch(line)
+		}
+		if matches == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(matches[3])
+		column, _ := strconv.Atoi(matches[4])
+		frames = append(frames, DiagnosticFrame{
+			Function: strings.TrimSpace(matches[1]),
+			File:     framePath(matches[2]),
+			Line:     lineNumber,
+			Column:   column,
+		})
+		if len(frames) == maxDiagnosticFrames {
+			break
+		}
+	}
+	return frames
+}
+
+// framePath reduces a frame URL to its path on the preview
+func framePath(raw string) string {
+	if parsed, err := url.Parse(raw); err == nil && parsed.Host != "" {
+		raw = parsed.Path
+	} else if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	return previewProxyPrefix.ReplaceAllString(raw, "")
+}
+
+// isBundleFrame reports whether a frame is in the served bundle
+func isBundleFrame(file string) bool {
+	return strings.HasSuffix(file, "/__apex_bundle.js")
+}
+
+// truncateDiagnostic caps a string at max bytes
+func truncateDiagnostic(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
+
+// RecordDiagnostics stores diagnostics reported by a project's preview page
+func (ps *PreviewServer) RecordDiagnostics(projectID uint, reports []DiagnosticReport) []Diagnostic {
+	if ps == nil || ps.diagnostics == nil {
+		return nil
+	}
+	var bundle *bundler.BundleResult
+	ps.mu.RLock()
+	session := ps.sessions[projectID]
+	ps.mu.RUnlock()
+	if session != nil {
+		session.mu.RLock()
+		bundle = session.BundleResult
+		session.mu.RUnlock()
+	}
+	return ps.diagnostics.Record(projectID, reports, bundle, time.Now())
+}
+
+// Diagnostics returns a project's diagnostics with IDs above sinceID
+func (ps *PreviewServer) Diagnostics(projectID uint, sinceID uint64) []Diagnostic {
+	if ps == nil || ps.diagnostics == nil {
+		return []Diagnostic{}
+	}
+	return ps.diagnostics.List(projectID, sinceID)
+}
+
+// Diagnostic returns one of a project's diagnostics
+func (ps *PreviewServer) Diagnostic(projectID uint, id uint64) (Diagnostic, bool) {
+	if ps == nil || ps.diagnostics == nil {
+		return Diagnostic{}, false
+	}
+	return ps.diagnostics.Get(projectID, id)
+}
+
+// ClearDiagnostics drops a project's diagnostics
+func (ps *PreviewServer) ClearDiagnostics(projectID uint) {
+	if ps != nil && ps.diagnostics != nil {
+		ps.diagnostics.Clear(projectID)
+	}
+}
+
+// createDiagnosticsHandler accepts reports posted by the injected script
+// when the preview is opened on its own port
+func (ps *PreviewServer) createDiagnosticsHandler(session *PreviewSession) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		reports, err := DecodeDiagnosticReports(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ps.RecordDiagnostics(session.ProjectID, reports)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,92 @@
+package preview
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/bundler"
+)
+
+// testSourceMap maps bundle line 1 to src/App.tsx line 1, and bundle line 2
+// column 5 onward to src/App.tsx line 2 column 5, named "boom"
+const testSourceMap = `{"version":3,"sources":["../src/App.tsx"],"names":["boom"],"mappings":"AAAA;AACA,IAAIA"}`
+
+func TestSourceMapLookup(t *testing.T) {
+	sm, err := parseSourceMap([]byte(testSourceMap))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	pos, ok := sm.lookup(2, 7)
+	if !ok || pos.Source != "src/App.tsx" || pos.Line != 2 || pos.Column != 5 || pos.Name != "boom" {
+		t.Fatalf("lookup(2, 7) = %+v, %v", pos, ok)
+	}
+	if pos, ok := sm.lookup(2, 2); !ok || pos.Column != 1 || pos.Name != "" {
+		t.Fatalf("lookup(2, 2) = %+v, %v", pos, ok)
+	}
+	if _, ok := sm.lookup(3, 1); ok {
+		t.Fatal("line past the map should not resolve")
+	}
+	if _, err := parseSourceMap([]byte(`{"version":3,"sources":[],"mappings":"AAAA"}`)); err == nil {
+		t.Fatal("mapping to a missing source should fail")
+	}
+}
+
+func TestDiagnosticsStoreMapsBundleFramesAndCollapsesRepeats(t *testing.T) {
+	store := NewDiagnosticsStore()
+	bundle := &bundler.BundleResult{Hash: "abc", SourceMap: []byte(testSourceMap)}
+	report := DiagnosticReport{
+		Kind:    DiagnosticKindError,
+		Level:   "warn",
+		Message: "TypeError: x is not a function",
+		Stack: "TypeError: x is not a function\n" +
+			"    at boom (http://localhost:9000/api/v1/preview/proxy/5/__apex_bundle.js?preview_token=t:2:7)\n" +
+			"    at http://localhost:9000/__apex_bundle.js:1:1",
+	}
+	now := time.Now()
+
+	recorded := store.Record(5, []DiagnosticReport{report, report, {Kind: "console", Message: "  "}}, bundle, now)
+	if len(recorded) != 2 {
+		t.Fatalf("recorded = %d, want the blank message dropped", len(recorded))
+	}
+	list := store.List(5, 0)
+	if len(list) != 1 || list[0].Count != 2 || list[0].Level != "error" {
+		t.Fatalf("list = %+v", list)
+	}
+	diagnostic := list[0]
+	if diagnostic.Source != "src/App.tsx:2:5" {
+		t.Fatalf("source = %q", diagnostic.Source)
+	}
+	if len(diagnostic.Frames) != 2 || diagnostic.Frames[0].Function != "boom" || diagnostic.Frames[1].File != "/__apex_bundle.js" {
+		t.Fatalf("frames = %+v", diagnostic.Frames)
+	}
+	if original := diagnostic.Frames[1].Original; original == nil || original.Line != 1 {
+		t.Fatalf("second frame original = %+v", original)
+	}
+
+	firefox := DiagnosticReport{Kind: "console", Level: "warn", Message: "slow render", Stack: "render@http://localhost:5173/src/List.tsx?t=1:40:12"}
+	store.Record(5, []DiagnosticReport{firefox}, nil, now)
+	list = store.List(5, diagnostic.ID)
+	if len(list) != 1 || list[0].Source != "src/List.tsx:40:12" || list[0].Level != "warn" {
+		t.Fatalf("since list = %+v", list)
+	}
+
+	store.Clear(5)
+	if len(store.List(5, 0)) != 0 {
+		t.Fatal("clear kept diagnostics")
+	}
+}
+
+func TestDecodeDiagnosticReports(t *testing.T) {
+	reports, err := DecodeDiagnosticReports(strings.NewReader(`[{"kind":"error","message":"a"}]`))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("array = %+v, %v", reports, err)
+	}
+	reports, err = DecodeDiagnosticReports(strings.NewReader(`{"reports":[{"message":"a"},{"message":"b"}]}`))
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("object = %+v, %v", reports, err)
+	}
+	if _, err := DecodeDiagnosticReports(strings.NewReader(`nope`)); err != ErrInvalidDiagnostics {
+		t.Fatalf("invalid err = %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -178,9 +179,34 @@ func (r *dockerPreviewBackendRuntime) StartProcess(cfg *ProcessStartConfig) (*Pr
 			_ = r.stopContainer(containerID, imageName)
 			_ = logCmd.Process.Kill()
 		},
+		WriteFile: func(relativePath string, content []byte) error {
+			return r.copyIntoContainer(containerID, relativePath, content)
+		},
 	}, nil
 }
 
+// copyIntoContainer writes a project file into a running container's /app
+func (r *dockerPreviewBackendRuntime) copyIntoContainer(containerID, relativePath string, content []byte) error {
+	tmp, err := os.CreateTemp("", "apex-sync-*")
+	if err != nil {
+		return fmt.Errorf("failed to stage %s: %w", relativePath, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to stage %s: %w", relativePath, err)
+	}
+	tmp.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	dest := containerID + ":" + path.Join("/app", relativePath)
+	if out, err := r.dockerCommandContext(ctx, "cp", tmp.Name(), dest).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy %s into preview container: %w\n%s", relativePath, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func (r *dockerPreviewBackendRuntime) checkDocker() error {
 	if _, err := exec.LookPath("docker"); err != nil {
 		return fmt.Errorf("docker CLI not found: %w", err)
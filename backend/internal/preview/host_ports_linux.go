@@ -0,0 +1,102 @@
+//go:build linux
+
+package preview
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ListeningPorts returns the TCP ports in LISTEN state owned by the
+// process group that pid leads (host processes get their own group).
+func (h *hostRuntime) ListeningPorts(pid int) ([]int, error) {
+	inodes := map[string]bool{}
+	for _, member := range processGroupMembers(pid) {
+		fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(member), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(member), "fd", fd.Name()))
+			if err == nil && strings.HasPrefix(link, "socket:[") {
+				inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil, nil
+	}
+
+	seen := map[int]bool{}
+	var ports []int
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, port := range listeningPortsForInodes(table, inodes) {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// processGroupMembers returns the pids whose process group is pgid.
+func processGroupMembers(pgid int) []int {
+	members := []int{pgid}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return members
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == pgid {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// Fields after the parenthesised command: state ppid pgrp ...
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) > 2 && fields[2] == strconv.Itoa(pgid) {
+			members = append(members, pid)
+		}
+	}
+	return members
+}
+
+// listeningPortsForInodes reads a /proc/net/tcp table and returns the
+// local ports of LISTEN sockets whose inode is in inodes.
+func listeningPortsForInodes(table string, inodes map[string]bool) []int {
+	file, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var ports []int
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+		if len(fields) < 10 || fields[3] != "0A" || !inodes[fields[9]] {
+			continue
+		}
+		colon := strings.LastIndexByte(fields[1], ':')
+		if colon < 0 {
+			continue
+		}
+		if port, err := strconv.ParseInt(fields[1][colon+1:], 16, 32); err == nil {
+			ports = append(ports, int(port))
+		}
+	}
+	return ports
+}
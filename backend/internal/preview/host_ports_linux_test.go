@@ -0,0 +1,29 @@
+//go:build linux
+
+package preview
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestHostRuntimeListeningPortsFindsOwnSockets(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	ports, err := (&hostRuntime{}).ListeningPorts(os.Getpid())
+	if err != nil {
+		t.Fatalf("ListeningPorts: %v", err)
+	}
+	for _, p := range ports {
+		if p == port {
+			return
+		}
+	}
+	t.Fatalf("ListeningPorts = %v, want %d", ports, port)
+}
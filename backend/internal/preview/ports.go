@@ -0,0 +1,240 @@
+package preview
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxExposedPorts caps the named ports a preview server can expose
+// besides its primary port.
+const MaxExposedPorts = 4
+
+var (
+	ErrServerNotRunning   = errors.New("backend server not running")
+	ErrInvalidPortMapping = errors.New("invalid port mapping")
+	ErrPortNotExposable   = errors.New("port cannot be exposed by this preview runtime")
+)
+
+// Port sources reported by DetectPorts.
+const (
+	PortSourceAssigned = "assigned" // the PORT the runner gave the process
+	PortSourceSocket   = "socket"   // seen listening by the process
+	PortSourceLogs     = "logs"     // announced in the process output
+)
+
+// ListeningPort is a port a preview server process listens on.
+type ListeningPort struct {
+	Port    int    `json:"port"`
+	Source  string `json:"source"`
+	Primary bool   `json:"primary"`
+	Exposed string `json:"exposed,omitempty"` // Name it is exposed under
+}
+
+// PortMapping chooses the port the preview proxy targets and names extra
+// ports exposed under their own proxy path.
+type PortMapping struct {
+	PrimaryPort int            `json:"primary_port"`
+	Exposed     map[string]int `json:"exposed"`
+}
+
+// runtimePortLister is implemented by runtimes that can see the sockets
+// of the processes they start.
+type runtimePortLister interface {
+	ListeningPorts(pid int) ([]int, error)
+}
+
+var (
+	logPortPattern     = regexp.MustCompile(`(?i)(?:localhost|127\.0\.0\.1|0\.0\.0\.0|\[::\]|\[::1\]):(\d{2,5})\b`)
+	logPortWordPattern = regexp.MustCompile(`(?i)\bport\s*[:=]?\s*(\d{2,5})\b`)
+	exposedNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,30}$`)
+)
+
+// portsFromLogs returns ports announced in server output, such as
+// "Listening on http://localhost:5173" or "API running on port 8000".
+func portsFromLogs(logs string) []int {
+	seen := map[int]bool{}
+	var ports []int
+	for _, pattern := range []*regexp.Regexp{logPortPattern, logPortWordPattern} {
+		for _, match := range pattern.FindAllStringSubmatch(logs, -1) {
+			port, err := strconv.Atoi(match[1])
+			if err != nil || port < 1 || port > 65535 || seen[port] {
+				continue
+			}
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// DetectPorts lists the ports the project's server listens on, with the
+// current primary and exposed assignments.
+func (sr *ServerRunner) DetectPorts(projectID uint) ([]ListeningPort, error) {
+	sr.mu.RLock()
+	proc, ok := sr.processes[projectID]
+	sr.mu.RUnlock()
+	if !ok || proc.ExitedAt != nil {
+		return nil, ErrServerNotRunning
+	}
+	return sr.detectPorts(proc), nil
+}
+
+func (sr *ServerRunner) detectPorts(proc *ServerProcess) []ListeningPort {
+	sources := map[int]string{proc.Port: PortSourceAssigned}
+	if lister, ok := sr.runtime.(runtimePortLister); ok && proc.Pid > 0 {
+		if ports, err := lister.ListeningPorts(proc.Pid); err == nil {
+			for _, port := range ports {
+				if _, known := sources[port]; !known {
+					sources[port] = PortSourceSocket
+				}
+			}
+		}
+	}
+	proc.bufMu.Lock()
+	logs := proc.Stdout.String() + "\n" + proc.Stderr.String()
+	proc.bufMu.Unlock()
+	for _, port := range portsFromLogs(logs) {
+		if _, known := sources[port]; !known {
+			sources[port] = PortSourceLogs
+		}
+	}
+
+	sr.mu.RLock()
+	mapping := proc.PortMapping
+	sr.mu.RUnlock()
+	primary := proc.Port
+	if mapping.PrimaryPort != 0 {
+		primary = mapping.PrimaryPort
+	}
+	names := make(map[int]string, len(mapping.Exposed))
+	for name, port := range mapping.Exposed {
+		names[port] = name
+	}
+
+	ports := make([]ListeningPort, 0, len(sources))
+	for port, source := range sources {
+		ports = append(ports, ListeningPort{
+			Port:    port,
+			Source:  source,
+			Primary: port == primary,
+			Exposed: names[port],
+		})
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// SetPortMapping points the preview proxy at a detected port and exposes
+// other detected ports by name. A zero PrimaryPort keeps the assigned port.
+func (sr *ServerRunner) SetPortMapping(projectID uint, mapping PortMapping) ([]ListeningPort, error) {
+	sr.mu.RLock()
+	proc, ok := sr.processes[projectID]
+	sr.mu.RUnlock()
+	if !ok || proc.ExitedAt != nil {
+		return nil, ErrServerNotRunning
+	}
+	if len(mapping.Exposed) > MaxExposedPorts {
+		return nil, fmt.Errorf("%w: at most %d exposed ports", ErrInvalidPortMapping, MaxExposedPorts)
+	}
+
+	detected := map[int]string{}
+	for _, port := range sr.detectPorts(proc) {
+		detected[port.Port] = port.Source
+	}
+	check := func(port int) error {
+		source, ok := detected[port]
+		if !ok {
+			return fmt.Errorf("%w: port %d is not listening", ErrInvalidPortMapping, port)
+		}
+		if _, err := portURL(proc, port, source); err != nil {
+			return fmt.Errorf("port %d: %w", port, err)
+		}
+		return nil
+	}
+	if mapping.PrimaryPort != 0 {
+		if err := check(mapping.PrimaryPort); err != nil {
+			return nil, err
+		}
+	}
+	exposed := make(map[string]int, len(mapping.Exposed))
+	for name, port := range mapping.Exposed {
+		if !exposedNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: name %q must be a lowercase slug", ErrInvalidPortMapping, name)
+		}
+		if err := check(port); err != nil {
+			return nil, err
+		}
+		exposed[name] = port
+	}
+	if mapping.PrimaryPort == proc.Port {
+		mapping.PrimaryPort = 0
+	}
+
+	sr.mu.Lock()
+	proc.PortMapping = PortMapping{PrimaryPort: mapping.PrimaryPort, Exposed: exposed}
+	proc.portSources = detected
+	sr.mu.Unlock()
+	return sr.detectPorts(proc), nil
+}
+
+// PortURL returns the URL of an exposed port; an empty name means the
+// primary port.
+func (sr *ServerRunner) PortURL(projectID uint, name string) (string, error) {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	proc, ok := sr.processes[projectID]
+	if !ok || proc.ExitedAt != nil {
+		return "", ErrServerNotRunning
+	}
+	port := proc.PortMapping.PrimaryPort
+	if name != "" {
+		var exposed bool
+		if port, exposed = proc.PortMapping.Exposed[name]; !exposed {
+			return "", fmt.Errorf("%w: no port exposed as %q", ErrInvalidPortMapping, name)
+		}
+	}
+	if port == 0 || port == proc.Port {
+		return proc.URL, nil
+	}
+	return portURL(proc, port, proc.portSources[port])
+}
+
+// portURL derives the address of another port from the process URL.
+// Local processes may only expose ports they were seen listening on, so a
+// mapping can never reach someone else's service on the host. Sandboxes
+// that route by a "<port>-" hostname prefix are isolated, so any port
+// works there. Other runtimes publish only the assigned port.
+func portURL(proc *ServerProcess, port int, source string) (string, error) {
+	if port == proc.Port {
+		return proc.URL, nil
+	}
+	base, err := url.Parse(proc.URL)
+	if err != nil {
+		return "", err
+	}
+	host := base.Hostname()
+	prefix := strconv.Itoa(proc.Port) + "-"
+	switch {
+	case strings.HasPrefix(host, prefix):
+		base.Host = strconv.Itoa(port) + "-" + strings.TrimPrefix(base.Host, prefix)
+	case proc.RuntimeType == "host" && source == PortSourceSocket && isLoopbackHost(host):
+		base.Host = net.JoinHostPort(host, strconv.Itoa(port))
+	default:
+		return "", ErrPortNotExposable
+	}
+	return base.String(), nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,104 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type fakePortListingRuntime struct {
+	name  string
+	ports []int
+}
+
+func (f *fakePortListingRuntime) StartProcess(*ProcessStartConfig) (*ProcessHandle, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakePortListingRuntime) Name() string { return f.name }
+
+func (f *fakePortListingRuntime) ListeningPorts(int) ([]int, error) { return f.ports, nil }
+
+func newPortTestRunner(runtimeName, url, logs string, listening ...int) *ServerRunner {
+	runner := NewServerRunnerWithRuntime(nil, &fakePortListingRuntime{name: runtimeName, ports: listening})
+	runner.processes[7] = &ServerProcess{
+		ProjectID:   7,
+		Port:        9100,
+		Pid:         4242,
+		RuntimeType: runtimeName,
+		URL:         url,
+		Stdout:      bytes.NewBufferString(logs),
+		Stderr:      &bytes.Buffer{},
+	}
+	return runner
+}
+
+func TestPortsFromLogs(t *testing.T) {
+	logs := "VITE ready\n  ➜  Local:   http://localhost:5173/\nAPI listening on port 8000\nproxy -> 127.0.0.1:8000\n"
+	if got := portsFromLogs(logs); !reflect.DeepEqual(got, []int{5173, 8000}) {
+		t.Fatalf("portsFromLogs = %v", got)
+	}
+}
+
+func TestSetPortMappingExposesOnlyListeningPorts(t *testing.T) {
+	runner := newPortTestRunner("host", "http://127.0.0.1:9100", "API on port 8000\n", 5173)
+
+	ports, err := runner.DetectPorts(7)
+	if err != nil {
+		t.Fatalf("DetectPorts: %v", err)
+	}
+	want := []ListeningPort{
+		{Port: 5173, Source: PortSourceSocket},
+		{Port: 8000, Source: PortSourceLogs},
+		{Port: 9100, Source: PortSourceAssigned, Primary: true},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Fatalf("ports = %+v", ports)
+	}
+
+	if _, err := runner.SetPortMapping(7, PortMapping{PrimaryPort: 5173, Exposed: map[string]int{"api": 9100}}); err != nil {
+		t.Fatalf("SetPortMapping: %v", err)
+	}
+	if url, _ := runner.PortURL(7, ""); url != "http://127.0.0.1:5173" {
+		t.Fatalf("primary URL = %q", url)
+	}
+	if url, _ := runner.PortURL(7, "api"); url != "http://127.0.0.1:9100" {
+		t.Fatalf("api URL = %q", url)
+	}
+	status := runner.GetStatus(7)
+	if status.URL != "http://127.0.0.1:5173" || status.PrimaryPort != 5173 || status.ExposedPorts["api"] != 9100 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if _, err := runner.SetPortMapping(7, PortMapping{Exposed: map[string]int{"db": 5432}}); !errors.Is(err, ErrInvalidPortMapping) {
+		t.Fatalf("unlistened port err = %v", err)
+	}
+	// A port only named in the logs could belong to another host process.
+	if _, err := runner.SetPortMapping(7, PortMapping{Exposed: map[string]int{"api": 8000}}); !errors.Is(err, ErrPortNotExposable) {
+		t.Fatalf("log-only host port err = %v", err)
+	}
+	if _, err := runner.SetPortMapping(7, PortMapping{Exposed: map[string]int{"API": 5173}}); !errors.Is(err, ErrInvalidPortMapping) {
+		t.Fatalf("bad name err = %v", err)
+	}
+	if _, err := runner.PortURL(7, "missing"); !errors.Is(err, ErrInvalidPortMapping) {
+		t.Fatalf("unknown name err = %v", err)
+	}
+}
+
+func TestSetPortMappingRoutesSandboxPortsByHostname(t *testing.T) {
+	runner := newPortTestRunner("e2b", "https://9100-sbx123.e2b.app", "uvicorn running on http://0.0.0.0:8000\n")
+
+	if _, err := runner.SetPortMapping(7, PortMapping{Exposed: map[string]int{"api": 8000}}); err != nil {
+		t.Fatalf("SetPortMapping: %v", err)
+	}
+	if url, _ := runner.PortURL(7, "api"); url != "https://8000-sbx123.e2b.app" {
+		t.Fatalf("api URL = %q", url)
+	}
+
+	container := newPortTestRunner("container", "http://localhost:9100", "listening on port 8000\n")
+	if _, err := container.SetPortMapping(7, PortMapping{Exposed: map[string]int{"api": 8000}}); !errors.Is(err, ErrPortNotExposable) {
+		t.Fatalf("container runtime err = %v", err)
+	}
+}
@@ -36,6 +36,8 @@ type PreviewServer struct {
 	portMap  map[uint]int // projectID -> assigned port
 	portMu   sync.Mutex
 	bundler  *bundler.Service // Bundler service for React/Vue/TypeScript projects
+	// diagnostics holds runtime errors reported by preview pages
+	diagnostics *DiagnosticsStore
 }
 
 // PreviewSession represents an active preview session
@@ -57,6 +59,37 @@ type PreviewSession struct {
 	BundleConfig *bundler.BundleConfig // Bundle configuration used
 }
 
+// cacheBundle adds a bundle's JavaScript, CSS and source map to the file
+// cache (caller must hold session.mu or own the session exclusively)
+func (session *PreviewSession) cacheBundle(result *bundler.BundleResult) {
+	now := time.Now()
+	js := result.LinkedJS("__apex_bundle.js.map")
+	session.FileCache["__apex_bundle.js"] = &CachedFile{
+		Content:     string(js),
+		ContentType: "application/javascript; charset=utf-8",
+		ProcessedAt: now,
+		Size:        int64(len(js)),
+	}
+	if len(result.SourceMap) > 0 {
+		session.FileCache["__apex_bundle.js.map"] = &CachedFile{
+			Content:     string(result.SourceMap),
+			ContentType: "application/json; charset=utf-8",
+			ProcessedAt: now,
+			Size:        int64(len(result.SourceMap)),
+		}
+	} else {
+		delete(session.FileCache, "__apex_bundle.js.map")
+	}
+	if len(result.OutputCSS) > 0 {
+		session.FileCache["__apex_bundle.css"] = &CachedFile{
+			Content:     string(result.OutputCSS),
+			ContentType: "text/css; charset=utf-8",
+			ProcessedAt: now,
+			Size:        int64(len(result.OutputCSS)),
+		}
+	}
+}
+
 func (ps *PreviewServer) projectTitle(ctx context.Context, projectID uint) string {
 	if ps == nil || ps.db == nil || projectID == 0 {
 		return ""
@@ -173,10 +206,11 @@ func NewPreviewServer(db *gorm.DB) *PreviewServer {
 	}
 
 	return &PreviewServer{
-		db:       db,
-		sessions: make(map[uint]*PreviewSession),
-		portMap:  make(map[uint]int),
-		basePort: 9000, // Preview ports start at 9000
+		db:          db,
+		sessions:    make(map[uint]*PreviewSession),
+		portMap:     make(map[uint]int),
+		basePort:    9000, // Preview ports start at 9000
+		diagnostics: NewDiagnosticsStore(),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				origin := strings.TrimSpace(r.Header.Get("Origin"))
@@ -254,6 +288,7 @@ func (ps *PreviewServer) StartPreview(ctx context.Context, config *PreviewConfig
 	mux.HandleFunc("/", ps.createFileHandler(session, config))
 	mux.HandleFunc("/__apex_reload", ps.createReloadHandler(session))
 	mux.HandleFunc("/__apex_ws", ps.createWebSocketHandler(session))
+	mux.HandleFunc("/__apex_diagnostics", ps.createDiagnosticsHandler(session))
 
 	session.server = &http.Server{
 		Addr:    listener.Addr().String(),
@@ -369,6 +404,7 @@ func (ps *PreviewServer) RefreshPreview(projectID uint, changedFiles []string) e
 	}
 
 	// Rebundle if needed and bundler is available
+	var bundleTiming *bundler.BundleTiming
 	if needsRebundle && ps.bundler != nil && ps.bundler.IsAvailable() {
 		session.mu.RLock()
 		wasBundled := session.IsBundled
@@ -390,21 +426,9 @@ func (ps *PreviewServer) RefreshPreview(projectID uint, changedFiles []string) e
 				session.mu.Lock()
 				session.BundleResult = result
 				session.IsBundled = true
-				session.FileCache["__apex_bundle.js"] = &CachedFile{
-					Content:     string(result.OutputJS),
-					ContentType: "application/javascript; charset=utf-8",
-					ProcessedAt: time.Now(),
-					Size:        int64(len(result.OutputJS)),
-				}
-				if len(result.OutputCSS) > 0 {
-					session.FileCache["__apex_bundle.css"] = &CachedFile{
-						Content:     string(result.OutputCSS),
-						ContentType: "text/css; charset=utf-8",
-						ProcessedAt: time.Now(),
-						Size:        int64(len(result.OutputCSS)),
-					}
-				}
+				session.cacheBundle(result)
 				session.mu.Unlock()
+				bundleTiming = result.Timing
 				log.Printf("[preview] Rebundle successful (JS: %d bytes, duration: %v)", len(result.OutputJS), result.Duration)
 			} else {
 				log.Printf("[preview] Rebundle failed with %d errors", len(result.Errors))
@@ -420,6 +444,9 @@ func (ps *PreviewServer) RefreshPreview(projectID uint, changedFiles []string) e
 		"type":  "reload",
 		"files": normalizedChanged,
 	}
+	if bundleTiming != nil {
+		message["bundle"] = bundleTiming
+	}
 	msgBytes, _ := json.Marshal(message)
 
 	for client := range session.Clients {
@@ -589,7 +616,17 @@ func (ps *PreviewServer) loadProjectFiles(ctx context.Context, session *PreviewS
 		if cachePath == "" {
 			continue
 		}
-		processed := ps.processFile(&file, config)
+		var processed string
+		if file.IsBinary {
+			data, err := fileBytes(ctx, &file)
+			if err != nil {
+				log.Printf("[preview] Project %d: skipping binary file %s: %v", config.ProjectID, file.Path, err)
+				continue
+			}
+			processed = string(data)
+		} else {
+			processed = ps.processFile(&file, config)
+		}
 		session.FileCache[cachePath] = &CachedFile{
 			Content:     processed,
 			ContentType: ps.getContentType(cachePath),
@@ -636,21 +673,7 @@ func (ps *PreviewServer) loadProjectFiles(ctx context.Context, session *PreviewS
 				session.BundleConfig = &bundleConfig
 
 				// Add bundled files to cache
-				session.FileCache["__apex_bundle.js"] = &CachedFile{
-					Content:     string(result.OutputJS),
-					ContentType: "application/javascript; charset=utf-8",
-					ProcessedAt: time.Now(),
-					Size:        int64(len(result.OutputJS)),
-				}
-
-				if len(result.OutputCSS) > 0 {
-					session.FileCache["__apex_bundle.css"] = &CachedFile{
-						Content:     string(result.OutputCSS),
-						ContentType: "text/css; charset=utf-8",
-						ProcessedAt: time.Now(),
-						Size:        int64(len(result.OutputCSS)),
-					}
-				}
+				session.cacheBundle(result)
 
 				// Generate or update index.html to load the bundle
 				if err := ps.generateBundledHTML(session, config); err != nil {
@@ -703,30 +726,22 @@ func (ps *PreviewServer) updateFileCache(ctx context.Context, session *PreviewSe
 		return lookupErr
 	}
 
+	content, err := fileBytes(ctx, &file)
+	if err != nil {
+		return err
+	}
+
 	session.mu.Lock()
 	session.FileCache[normalizedPath] = &CachedFile{
-		Content:     file.Content,
+		Content:     string(content),
 		ContentType: ps.getContentType(normalizedPath),
 		ProcessedAt: time.Now(),
 		Size:        file.Size,
 	}
 
-	// If this is a bundled project and a source file changed, invalidate the bundle
-	if session.IsBundled {
-		ext := strings.ToLower(filepath.Ext(normalizedPath))
-		needsRebundle := ext == ".ts" || ext == ".tsx" || ext == ".js" || ext == ".jsx" ||
-			ext == ".css" || ext == ".scss" || ext == ".json"
-
-		if needsRebundle {
-			log.Printf("[preview] Source file %s changed, invalidating bundle for project %d", normalizedPath, session.ProjectID)
-			// Invalidate the bundle cache
-			if ps.bundler != nil {
-				ps.bundler.InvalidateCache(session.ProjectID)
-			}
-			session.IsBundled = false
-			session.BundleResult = nil
-		}
-	}
+	// A bundled project keeps its bundle until RefreshPreview rebuilds it.
+	// Bundle cache keys include the file hash, so the old bundle is never
+	// served for the new files, and the rebuild only rewrites changed files.
 	session.mu.Unlock()
 
 	return nil
@@ -901,6 +916,46 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
 	script := `
 <script>
 (function() {
+  const proxyMatch = window.location.pathname.match(/^\/api\/v1\/preview\/proxy\/\d+/);
+  const proxyBase = proxyMatch ? proxyMatch[0] : '';
+  const originalFetch = window.fetch;
+
+  // ============================================
+  // APEX Diagnostics Reporting
+  // ============================================
+  // Errors and warnings are batched and posted to the preview server, which
+  // maps bundle stack frames back to the original sources
+  const diagnosticsUrl = proxyBase + '/__apex_diagnostics' + (window.location.search || '');
+  let diagnosticsQueue = [];
+  let diagnosticsTimer = null;
+
+  function reportDiagnostic(report) {
+    if (diagnosticsQueue.length >= 50) return;
+    report.url = window.location.href;
+    diagnosticsQueue.push(report);
+    if (!diagnosticsTimer) {
+      diagnosticsTimer = setTimeout(flushDiagnostics, 1000);
+    }
+  }
+
+  function flushDiagnostics() {
+    diagnosticsTimer = null;
+    if (diagnosticsQueue.length === 0) return;
+    const body = JSON.stringify(diagnosticsQueue);
+    diagnosticsQueue = [];
+    try {
+      originalFetch.call(window, diagnosticsUrl, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: body,
+        credentials: 'same-origin',
+        keepalive: true
+      }).catch(() => {});
+    } catch (e) {}
+  }
+
+  window.addEventListener('pagehide', flushDiagnostics);
+
   // ============================================
   // APEX Console Interception
   // ============================================
@@ -913,6 +968,7 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
         const message = args.map(arg => {
           if (arg === null) return 'null';
           if (arg === undefined) return 'undefined';
+          if (arg instanceof Error) return arg.stack || String(arg);
           if (typeof arg === 'object') {
             try { return JSON.stringify(arg, null, 2); }
             catch { return String(arg); }
@@ -925,6 +981,15 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
           message: message,
           timestamp: new Date().toISOString()
         }, '*');
+        if ((method === 'error' || method === 'warn') && !message.startsWith('[APEX]')) {
+          const error = args.find(arg => arg instanceof Error);
+          reportDiagnostic({
+            kind: 'console',
+            level: method,
+            message: error ? String(error) : message,
+            stack: error?.stack || ''
+          });
+        }
       } catch (e) {}
     };
   });
@@ -938,6 +1003,15 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
       stack: error?.stack || '',
       timestamp: new Date().toISOString()
     }, '*');
+    reportDiagnostic({
+      kind: 'error',
+      level: 'error',
+      message: String(msg),
+      stack: error?.stack || '',
+      filename: url || '',
+      line: line || 0,
+      column: col || 0
+    });
     return false;
   };
 
@@ -950,6 +1024,12 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
       stack: event.reason?.stack || '',
       timestamp: new Date().toISOString()
     }, '*');
+    reportDiagnostic({
+      kind: 'unhandledrejection',
+      level: 'error',
+      message: 'Unhandled Promise Rejection: ' + (event.reason?.message || event.reason),
+      stack: event.reason?.stack || ''
+    });
   };
 
   // ============================================
@@ -958,7 +1038,6 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
   let requestIdCounter = 0;
 
   // Intercept fetch
-  const originalFetch = window.fetch;
   window.fetch = function(...args) {
     const requestId = ++requestIdCounter;
     const startTime = performance.now();
@@ -1030,8 +1109,6 @@ func (ps *PreviewServer) injectHotReloadScript(html string, config *PreviewConfi
   // APEX Hot Reload WebSocket
   // ============================================
   const wsProtocol = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
-  const proxyMatch = window.location.pathname.match(/^\/api\/v1\/preview\/proxy\/\d+/);
-  const proxyBase = proxyMatch ? proxyMatch[0] : '';
   const wsPath = (proxyBase ? proxyBase : '') + '/__apex_ws' + (window.location.search || '');
   const wsUrl = wsProtocol + window.location.host + wsPath;
   let wsReconnectAttempts = 0;
@@ -48,10 +48,22 @@ type runtimeCanaryTester interface {
 	RunCanaryInteractions(ctx context.Context, pageURL string) *CanaryResult
 }
 
+type runtimeSmokeRunner interface {
+	Available() bool
+	Mode() SmokeMode
+	RunSmokeSuite(ctx context.Context, baseURL string, suite *SmokeSuite) *SmokeResult
+}
+
+type runtimeSmokePlanner interface {
+	PlanSmokeSuite(ctx context.Context, files []VerifiableFile) *SmokeSuite
+}
+
 type RuntimeVerifier struct {
 	browser        *BrowserVerifier      // nil = browser proof disabled
 	visionVerifier runtimeVisionVerifier // nil = screenshot vision review disabled
 	canary         runtimeCanaryTester   // nil = interaction canary disabled
+	smoke          runtimeSmokeRunner    // nil = generated E2E smoke suite disabled
+	smokePlanner   runtimeSmokePlanner   // nil = smoke suite derived from the source
 	totalTimeout   time.Duration
 	installTimeout time.Duration
 	readyTimeout   time.Duration
@@ -76,6 +88,8 @@ func NewRuntimeVerifierWithBrowserPath(chromePath string) *RuntimeVerifier {
 		browser:        NewBrowserVerifierWithChromePath(chromePath),
 		visionVerifier: NewVisionVerifierFromEnv(),
 		canary:         NewCanaryTesterWithChromePath(chromePath),
+		smoke:          NewSmokeRunnerWithChromePath(chromePath),
+		smokePlanner:   NewSmokePlannerFromEnv(),
 	}
 }
 
@@ -97,6 +111,7 @@ type RuntimeVerificationResult struct {
 	CanaryPostInteractionChecked bool
 	CanaryPostInteractionHealthy bool
 	VisionSeverity               string // "critical", "advisory", "clean", or "" when vision skipped
+	SmokeStepsRun                int    // generated E2E smoke steps executed (0 = suite skipped)
 }
 
 // ── Public entry point ────────────────────────────────────────────────────────
@@ -340,6 +355,9 @@ func (rv *RuntimeVerifier) VerifyViteApp(ctx context.Context, files []Verifiable
 			}
 			result.Checks = append(result.Checks, check("browser_page_load", true, detail))
 			rv.applyAdvisoryBrowserSignals(bootCtx, result, baseURL, br)
+			if failed := rv.runSmokeSuite(bootCtx, result, baseURL, files, start, viteLogs.String()); failed != nil {
+				return failed
+			}
 		}
 	}
 
@@ -406,6 +424,49 @@ func (rv *RuntimeVerifier) applyAdvisoryBrowserSignals(ctx context.Context, resu
 	}
 }
 
+// runSmokeSuite replays the generated E2E smoke suite after the page-load
+// proof passed. In enforce mode a failing journey becomes a "smoke_failed"
+// result so the preview gate can route it into the repair loop; in advisory
+// mode failures are surfaced as prefixed repair hints only.
+func (rv *RuntimeVerifier) runSmokeSuite(ctx context.Context, result *RuntimeVerificationResult, baseURL string, files []VerifiableFile, start time.Time, serverLogs string) *RuntimeVerificationResult {
+	if rv.smoke == nil || !rv.smoke.Available() || result == nil {
+		return nil
+	}
+	var suite *SmokeSuite
+	if rv.smokePlanner != nil {
+		suite = rv.smokePlanner.PlanSmokeSuite(ctx, files)
+	} else {
+		suite = DeriveSmokeSuite(files)
+	}
+	smoke := rv.smoke.RunSmokeSuite(ctx, baseURL, suite)
+	if smoke == nil || smoke.Skipped {
+		return nil
+	}
+	result.SmokeStepsRun = len(smoke.Steps)
+	if smoke.Passed {
+		result.Checks = append(result.Checks, check("e2e_smoke", true, fmt.Sprintf("%d smoke step(s) passed", len(smoke.Steps))))
+		return nil
+	}
+
+	details := fmt.Sprintf("generated E2E smoke suite failed: %s", summarizeIssues(smoke.Errors, 3))
+	if rv.smoke.Mode() != SmokeModeEnforce {
+		result.Checks = append(result.Checks, check("e2e_smoke", true, "advisory: "+details))
+		result.RepairHints = appendUniqueStrings(result.RepairHints, prefixAll("smoke:", smoke.RepairHints)...)
+		return nil
+	}
+
+	hint := "Fix the user journeys exercised by the generated smoke suite."
+	if len(smoke.RepairHints) > 0 {
+		hint = smoke.RepairHints[0]
+	}
+	failed := rv.rtFailWithLogs("smoke_failed", details, hint, start, truncateLog(serverLogs, 1500))
+	failed.Checks = append(append([]CheckResult(nil), result.Checks...), check("e2e_smoke", false, details))
+	failed.RepairHints = appendUniqueStrings(failed.RepairHints, smoke.RepairHints...)
+	failed.ScreenshotData = result.ScreenshotData
+	failed.SmokeStepsRun = result.SmokeStepsRun
+	return failed
+}
+
 // ── Private helpers ────────────────────────────────────────────────────────────
 
 func (rv *RuntimeVerifier) prepareWorkDir(files []VerifiableFile) (dir string, cleanup func(), err error) {
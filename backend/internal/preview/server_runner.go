@@ -34,6 +34,13 @@ type ServerRunner struct {
 	portStart int
 	portMap   map[uint]int // projectID -> assigned backend port
 	portMu    sync.Mutex
+	logs      LogForwarder
+}
+
+// LogForwarder receives each line preview servers write, for example to
+// send it to a project's log drains. Implementations must not block.
+type LogForwarder interface {
+	ForwardPreviewLog(projectID uint, stream, line string)
 }
 
 // ServerProcess represents a running backend server
@@ -58,6 +65,9 @@ type ServerProcess struct {
 	WorkDir     string
 	CleanupDir  string
 	EnvVars     map[string]string
+	InjectedEnv []string // Names of project variables added to EnvVars
+	PortMapping PortMapping
+	portSources map[int]string // How each mapped port was detected
 	stopChan    chan struct{}
 	stoppedChan chan struct{}
 	stopOnce    sync.Once
@@ -101,22 +111,28 @@ type ServerConfig struct {
 	// The caller context still controls detection, dependency installation, and
 	// build preparation before process launch.
 	ReadyTimeout time.Duration `json:"-"`
+	// InjectedEnv names the EnvVars entries that came from the project's
+	// environment rather than the request; reported in ServerStatus.
+	InjectedEnv []string `json:"-"`
 }
 
 // ServerStatus represents the current state of a backend server
 type ServerStatus struct {
-	Running       bool       `json:"running"`
-	Port          int        `json:"port,omitempty"`
-	Pid           int        `json:"pid,omitempty"`
-	UptimeSeconds int64      `json:"uptime_seconds,omitempty"`
-	Command       string     `json:"command,omitempty"`
-	EntryFile     string     `json:"entry_file,omitempty"`
-	URL           string     `json:"url,omitempty"`
-	StartedAt     time.Time  `json:"started_at,omitempty"`
-	Ready         bool       `json:"ready"`
-	ExitedAt      *time.Time `json:"exited_at,omitempty"`
-	ExitCode      int        `json:"exit_code,omitempty"`
-	LastError     string     `json:"last_error,omitempty"`
+	Running       bool           `json:"running"`
+	Port          int            `json:"port,omitempty"`
+	Pid           int            `json:"pid,omitempty"`
+	UptimeSeconds int64          `json:"uptime_seconds,omitempty"`
+	Command       string         `json:"command,omitempty"`
+	EntryFile     string         `json:"entry_file,omitempty"`
+	URL           string         `json:"url,omitempty"`
+	StartedAt     time.Time      `json:"started_at,omitempty"`
+	Ready         bool           `json:"ready"`
+	ExitedAt      *time.Time     `json:"exited_at,omitempty"`
+	ExitCode      int            `json:"exit_code,omitempty"`
+	LastError     string         `json:"last_error,omitempty"`
+	InjectedEnv   []string       `json:"injected_env,omitempty"`
+	PrimaryPort   int            `json:"primary_port,omitempty"`
+	ExposedPorts  map[string]int `json:"exposed_ports,omitempty"`
 }
 
 // ServerLogs contains captured server output
@@ -168,6 +184,9 @@ type ProcessHandle struct {
 	SignalStop func()
 	// ForceKill immediately kills the process group (SIGKILL on host).
 	ForceKill func()
+	// WriteFile copies a saved project file into the running process, for
+	// runtimes that do not run from the local work directory (optional).
+	WriteFile func(relativePath string, content []byte) error
 }
 
 // hostRuntime starts server processes directly via os/exec on the host.
@@ -252,6 +271,11 @@ func NewServerRunnerWithRuntime(db *gorm.DB, rt RuntimeBackend) *ServerRunner {
 	}
 }
 
+// SetLogForwarder forwards preview server output line by line
+func (sr *ServerRunner) SetLogForwarder(forwarder LogForwarder) {
+	sr.logs = forwarder
+}
+
 // RuntimeName returns the active runtime backend name for diagnostics.
 func (sr *ServerRunner) RuntimeName() string {
 	if sr == nil || sr.runtime == nil {
@@ -586,6 +610,7 @@ func (sr *ServerRunner) Start(ctx context.Context, config *ServerConfig) (*Serve
 		WorkDir:     workDir,
 		CleanupDir:  strings.TrimSpace(config.CleanupDir),
 		EnvVars:     config.EnvVars,
+		InjectedEnv: config.InjectedEnv,
 		stopChan:    make(chan struct{}),
 		stoppedChan: make(chan struct{}),
 	}
@@ -594,8 +619,8 @@ func (sr *ServerRunner) Start(ctx context.Context, config *ServerConfig) (*Serve
 	}
 
 	// Start output capture goroutines
-	go sr.captureOutput(handle.StdoutPipe, stdout, proc)
-	go sr.captureOutput(handle.StderrPipe, stderr, proc)
+	go sr.captureOutput(handle.StdoutPipe, stdout, proc, "stdout")
+	go sr.captureOutput(handle.StderrPipe, stderr, proc, "stderr")
 
 	// Wait for process completion in background
 	go func() {
@@ -980,6 +1005,14 @@ func (sr *ServerRunner) GetStatus(projectID uint) *ServerStatus {
 	}
 	running := proc.ExitedAt == nil
 	ready := proc.Ready && running
+	targetURL := proc.URL
+	primaryPort := proc.Port
+	if mapped := proc.PortMapping.PrimaryPort; mapped != 0 {
+		if u, err := portURL(proc, mapped, proc.portSources[mapped]); err == nil {
+			targetURL = u
+			primaryPort = mapped
+		}
+	}
 	return &ServerStatus{
 		Running:       running,
 		Port:          proc.Port,
@@ -987,12 +1020,15 @@ func (sr *ServerRunner) GetStatus(projectID uint) *ServerStatus {
 		UptimeSeconds: uptime,
 		Command:       proc.Command,
 		EntryFile:     proc.EntryFile,
-		URL:           proc.URL,
+		URL:           targetURL,
 		StartedAt:     proc.StartedAt,
 		Ready:         ready,
 		ExitedAt:      proc.ExitedAt,
 		ExitCode:      proc.ExitCode,
 		LastError:     proc.LastError,
+		InjectedEnv:   proc.InjectedEnv,
+		PrimaryPort:   primaryPort,
+		ExposedPorts:  proc.PortMapping.Exposed,
 	}
 }
 
@@ -1140,8 +1176,10 @@ func (sr *ServerRunner) shouldInstallDependenciesLocally() bool {
 	return true
 }
 
-func (sr *ServerRunner) captureOutput(pipe io.ReadCloser, buf *bytes.Buffer, proc *ServerProcess) {
+func (sr *ServerRunner) captureOutput(pipe io.ReadCloser, buf *bytes.Buffer, proc *ServerProcess, stream string) {
 	defer pipe.Close()
+	lines := newLineForwarder(sr.logs, proc.ProjectID, stream)
+	defer lines.flush()
 
 	// Read in chunks
 	buffer := make([]byte, 4096)
@@ -1162,6 +1200,7 @@ func (sr *ServerRunner) captureOutput(pipe io.ReadCloser, buf *bytes.Buffer, pro
 					buf.Write(data[len(data)-5*1024*1024:])
 				}
 				proc.bufMu.Unlock()
+				lines.write(buffer[:n])
 			}
 			if err != nil {
 				return
@@ -1170,6 +1209,56 @@ func (sr *ServerRunner) captureOutput(pipe io.ReadCloser, buf *bytes.Buffer, pro
 	}
 }
 
+// lineForwarder splits output chunks into lines for a LogForwarder
+type lineForwarder struct {
+	forwarder LogForwarder
+	projectID uint
+	stream    string
+	partial   []byte
+}
+
+// maxForwardedLine bounds a line held back waiting for its newline
+const maxForwardedLine = 16 * 1024
+
+func newLineForwarder(forwarder LogForwarder, projectID uint, stream string) *lineForwarder {
+	return &lineForwarder{forwarder: forwarder, projectID: projectID, stream: stream}
+}
+
+func (l *lineForwarder) write(chunk []byte) {
+	if l.forwarder == nil {
+		return
+	}
+	l.partial = append(l.partial, chunk...)
+	for {
+		newline := bytes.IndexByte(l.partial, '\n')
+		if newline < 0 {
+			break
+		}
+		l.emit(l.partial[:newline])
+		l.partial = l.partial[newline+1:]
+	}
+	if len(l.partial) > maxForwardedLine {
+		l.flush()
+	}
+	// Release the consumed prefix
+	l.partial = append([]byte(nil), l.partial...)
+}
+
+func (l *lineForwarder) flush() {
+	if l.forwarder == nil || len(l.partial) == 0 {
+		return
+	}
+	l.emit(l.partial)
+	l.partial = nil
+}
+
+func (l *lineForwarder) emit(line []byte) {
+	text := strings.TrimRight(string(line), "\r")
+	if strings.TrimSpace(text) != "" {
+		l.forwarder.ForwardPreviewLog(l.projectID, l.stream, text)
+	}
+}
+
 func (sr *ServerRunner) getLastLines(s string, maxLines int) string {
 	lines := strings.Split(s, "\n")
 	if len(lines) <= maxLines {
@@ -1208,7 +1297,11 @@ func (sr *ServerRunner) writeProjectFiles(ctx context.Context, projectID uint, w
 			return fmt.Errorf("failed to create directory %s: %w", dirPath, err)
 		}
 
-		if err := os.WriteFile(filePath, []byte(file.Content), 0644); err != nil {
+		content, err := fileBytes(ctx, &file)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", file.Path, err)
+		}
+		if err := os.WriteFile(filePath, content, 0644); err != nil {
 			return fmt.Errorf("failed to write file %s: %w", filePath, err)
 		}
 	}
@@ -1216,6 +1309,38 @@ func (sr *ServerRunner) writeProjectFiles(ctx context.Context, projectID uint, w
 	return nil
 }
 
+// SyncFile writes a saved file into a running server's workspace, so a dev
+// server watching it (Vite, webpack, Next.js) pushes a hot update to the
+// page. It reports whether the running process sees the change.
+func (sr *ServerRunner) SyncFile(projectID uint, filePath string, content []byte) (bool, error) {
+	proc := sr.GetProcess(projectID)
+	if proc == nil || proc.WorkDir == "" {
+		return false, nil
+	}
+	relativePath := normalizeServerProjectPath(filePath)
+	if relativePath == "" {
+		return false, fmt.Errorf("invalid file path: %s", filePath)
+	}
+
+	// The local copy is kept current for every runtime, so a restart
+	// starts from the saved files
+	fullPath := filepath.Join(proc.WorkDir, relativePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", relativePath, err)
+	}
+	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to write file %s: %w", relativePath, err)
+	}
+
+	if proc.handle != nil && proc.handle.WriteFile != nil {
+		if err := proc.handle.WriteFile(filepath.ToSlash(relativePath), content); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return proc.RuntimeType == "host", nil
+}
+
 func normalizeServerProjectPath(path string) string {
 	trimmed := strings.TrimSpace(path)
 	if trimmed == "" {
@@ -3,6 +3,9 @@ package preview
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"apex-build/pkg/models"
@@ -288,3 +291,60 @@ func TestDetectServerTreatsNextAppRouterAsRuntimePreview(t *testing.T) {
 		t.Fatalf("entry file = %q, want app/page.tsx", detection.EntryFile)
 	}
 }
+
+type recordingLogForwarder struct {
+	lines []string
+}
+
+func (r *recordingLogForwarder) ForwardPreviewLog(projectID uint, stream, line string) {
+	r.lines = append(r.lines, fmt.Sprintf("%d %s %s", projectID, stream, line))
+}
+
+func TestLineForwarderSplitsChunksIntoLines(t *testing.T) {
+	recorder := &recordingLogForwarder{}
+	lines := newLineForwarder(recorder, 8, "stderr")
+
+	lines.write([]byte("listening on :3000\r\nGET /api"))
+	lines.write([]byte("/orders 500\n\n  \nunterminated"))
+	lines.flush()
+
+	want := []string{
+		"8 stderr listening on :3000",
+		"8 stderr GET /api/orders 500",
+		"8 stderr unterminated",
+	}
+	if strings.Join(recorder.lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("forwarded %q, want %q", recorder.lines, want)
+	}
+}
+
+func TestSyncFileWritesIntoRunningServerWorkspace(t *testing.T) {
+	runner := newPortTestRunner("host", "http://127.0.0.1:9100", "")
+	runner.processes[7].WorkDir = t.TempDir()
+	runner.processes[7].Ready = true
+
+	synced, err := runner.SyncFile(7, "/src/App.tsx", []byte("export default 1"))
+	if err != nil || !synced {
+		t.Fatalf("SyncFile = %v, %v", synced, err)
+	}
+	written, err := os.ReadFile(filepath.Join(runner.processes[7].WorkDir, "src", "App.tsx"))
+	if err != nil || string(written) != "export default 1" {
+		t.Fatalf("workspace file = %q, %v", written, err)
+	}
+	if _, err := runner.SyncFile(7, "../escape.js", nil); err == nil {
+		t.Fatal("path outside the workspace should fail")
+	}
+	if synced, err := runner.SyncFile(8, "src/App.tsx", nil); synced || err != nil {
+		t.Fatalf("SyncFile without a server = %v, %v", synced, err)
+	}
+
+	var copied string
+	runner.processes[7].RuntimeType = "docker"
+	runner.processes[7].handle = &ProcessHandle{WriteFile: func(rel string, _ []byte) error {
+		copied = rel
+		return nil
+	}}
+	if synced, err := runner.SyncFile(7, "src/App.tsx", []byte("x")); !synced || err != nil || copied != "src/App.tsx" {
+		t.Fatalf("container SyncFile = %v, %v, copied %q", synced, err, copied)
+	}
+}
@@ -0,0 +1,809 @@
+// smoke_suite.go — Generated browser E2E smoke suite for final preview validation.
+//
+// HTTP probing and the interaction canary prove that a preview boots and
+// survives a few clicks. The smoke suite goes one step further: a planning
+// agent reads the generated source and picks a small set of user journeys
+// (route visits, login, and the main create flow), which are rendered as a
+// Playwright spec that ships with the build artifacts and replayed in
+// headless Chrome against the running preview. When no model is configured,
+// or its plan is unusable, the journeys are derived from the source instead.
+//
+// Every run records a step trace and one screenshot frame per step under the
+// artifact directory so failures can be inspected after the fact. Runs older
+// than a day, and all but the newest runs, are pruned. Failing journeys are
+// reported as "smoke_failed" so the agent recovery loop can repair them before
+// the build is declared preview-ready.
+//
+// The suite is opt-in: set APEX_E2E_SMOKE to "advisory" or "enforce".
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/internal/ai"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/google/uuid"
+)
+
+// SmokeStepKind identifies the journey a smoke step exercises.
+type SmokeStepKind string
+
+const (
+	SmokeStepVisit  SmokeStepKind = "visit"
+	SmokeStepLogin  SmokeStepKind = "login"
+	SmokeStepCreate SmokeStepKind = "create"
+)
+
+// SmokeMode controls whether smoke failures block preview verification.
+type SmokeMode string
+
+const (
+	SmokeModeOff      SmokeMode = "off"
+	SmokeModeAdvisory SmokeMode = "advisory"
+	SmokeModeEnforce  SmokeMode = "enforce"
+)
+
+const (
+	smokeMaxVisitSteps = 5
+	smokeMaxSteps      = 8
+	smokeStepTimeout   = 20 * time.Second
+	smokeSuiteTimeout  = 90 * time.Second
+	smokePlanTimeout   = 30 * time.Second
+	smokeSettleDelay   = 750 * time.Millisecond
+	smokeTestEmail     = "smoke@apex.build"
+	smokeTestPassword  = "SmokeTest123!"
+	smokeTestText      = "Apex smoke test item"
+
+	// Source excerpts sent to the planning agent
+	smokePlanMaxFileBytes  = 4000
+	smokePlanMaxTotalBytes = 24000
+
+	// Artifact retention under the artifact root
+	smokeArtifactRetention = 24 * time.Hour
+	smokeArtifactMaxRuns   = 50
+)
+
+// Smoke suite sources.
+const (
+	SmokeSourceAgent     = "agent"
+	SmokeSourceHeuristic = "heuristic"
+)
+
+// SmokeStep is a single journey in a generated smoke suite.
+type SmokeStep struct {
+	Name string        `json:"name"`
+	Kind SmokeStepKind `json:"kind"`
+	Path string        `json:"path"`
+}
+
+// SmokeSuite is the ordered set of journeys planned for generated files.
+type SmokeSuite struct {
+	Steps []SmokeStep `json:"steps"`
+	// Source is SmokeSourceAgent or SmokeSourceHeuristic.
+	Source string `json:"source,omitempty"`
+}
+
+// SmokeTraceEvent is one entry in the recorded smoke trace.
+type SmokeTraceEvent struct {
+	Step      string `json:"step"`
+	Action    string `json:"action"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	OffsetMS  int64  `json:"offset_ms"`
+	FramePath string `json:"frame_path,omitempty"`
+}
+
+// SmokeStepResult is the outcome of a single smoke step.
+type SmokeStepResult struct {
+	Step     SmokeStep     `json:"step"`
+	Passed   bool          `json:"passed"`
+	Errors   []string      `json:"errors,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// SmokeResult is returned by SmokeRunner.RunSmokeSuite.
+type SmokeResult struct {
+	Passed      bool
+	Skipped     bool
+	Steps       []SmokeStepResult
+	Errors      []string
+	RepairHints []string
+	Trace       []SmokeTraceEvent
+	ArtifactDir string
+	Duration    time.Duration
+}
+
+// SmokeRunner replays a SmokeSuite in headless Chrome.
+type SmokeRunner struct {
+	chromePath  string
+	mode        SmokeMode
+	artifactDir string
+}
+
+// NewSmokeRunnerWithChromePath returns a runner pinned to a startup-smoked
+// Chrome binary. Mode and artifact location come from the environment.
+func NewSmokeRunnerWithChromePath(chromePath string) *SmokeRunner {
+	return &SmokeRunner{
+		chromePath:  strings.TrimSpace(chromePath),
+		mode:        E2ESmokeMode(),
+		artifactDir: smokeArtifactRoot(),
+	}
+}
+
+// Available reports whether the runner can execute smoke suites.
+func (sr *SmokeRunner) Available() bool {
+	return sr != nil && sr.chromePath != "" && sr.mode != SmokeModeOff
+}
+
+// Mode returns the configured enforcement mode.
+func (sr *SmokeRunner) Mode() SmokeMode {
+	if sr == nil {
+		return SmokeModeOff
+	}
+	return sr.mode
+}
+
+// E2ESmokeMode reads APEX_E2E_SMOKE. The suite is off by default;
+// "advisory" keeps results as repair hints only and "enforce" (or "true")
+// fails preview verification when a journey fails.
+func E2ESmokeMode() SmokeMode {
+	switch strings.TrimSpace(strings.ToLower(os.Getenv("APEX_E2E_SMOKE"))) {
+	case "1", "true", "yes", "on", "enforce":
+		return SmokeModeEnforce
+	case "advisory", "warn":
+		return SmokeModeAdvisory
+	default:
+		return SmokeModeOff
+	}
+}
+
+func smokeArtifactRoot() string {
+	if dir := strings.TrimSpace(os.Getenv("APEX_E2E_SMOKE_ARTIFACT_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "apex-smoke-artifacts")
+}
+
+var (
+	smokeJSXRoutePattern    = regexp.MustCompile(`<Route[^>]*\bpath=["'{]+(/[^"'}:*]*)["'}]`)
+	smokeObjectRoutePattern = regexp.MustCompile(`\bpath:\s*["'](/[^"':*]*)["']`)
+	smokePasswordPattern    = regexp.MustCompile(`type=\{?["']password["']`)
+	smokeFormPattern        = regexp.MustCompile(`(?i)<form\b[^>]*onSubmit`)
+)
+
+// smokeSuiteGenerator is the model call the SmokePlanner makes.
+type smokeSuiteGenerator interface {
+	Generate(ctx context.Context, req *ai.AIRequest) (*ai.AIResponse, error)
+}
+
+// SmokePlanner asks a model to write the smoke suite for a build. Like the
+// vision verifier it is optional: a nil planner, a failed call or an unusable
+// plan all fall back to DeriveSmokeSuite.
+type SmokePlanner struct {
+	generator smokeSuiteGenerator
+}
+
+// NewSmokePlanner returns nil when generator is nil.
+func NewSmokePlanner(generator smokeSuiteGenerator) *SmokePlanner {
+	if generator == nil {
+		return nil
+	}
+	return &SmokePlanner{generator: generator}
+}
+
+// NewSmokePlannerFromEnv uses the platform Anthropic key, or returns nil when
+// it isn't set or the smoke suite is off.
+func NewSmokePlannerFromEnv() *SmokePlanner {
+	if E2ESmokeMode() == SmokeModeOff {
+		return nil
+	}
+	apiKey := strings.TrimSpace(os.Getenv("ANTHROPIC_API_KEY"))
+	if apiKey == "" {
+		return nil
+	}
+	return NewSmokePlanner(ai.NewClaudeClient(apiKey))
+}
+
+// PlanSmokeSuite returns the agent-written suite for files, or the derived
+// one when the agent is unavailable.
+func (sp *SmokePlanner) PlanSmokeSuite(ctx context.Context, files []VerifiableFile) *SmokeSuite {
+	derived := DeriveSmokeSuite(files)
+	if sp == nil || sp.generator == nil {
+		return derived
+	}
+
+	planCtx, cancel := context.WithTimeout(ctx, smokePlanTimeout)
+	defer cancel()
+	resp, err := sp.generator.Generate(planCtx, &ai.AIRequest{
+		ID:          "smoke-plan-" + uuid.New().String(),
+		Capability:  ai.CapabilityTesting,
+		Prompt:      smokePlanPrompt(files, derived),
+		MaxTokens:   1200,
+		Temperature: 0,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil || resp == nil {
+		log.Printf("[e2e_smoke] planning agent unavailable, using derived suite: %v", err)
+		return derived
+	}
+	suite := parseSmokePlan(resp.Content)
+	if suite == nil {
+		log.Printf("[e2e_smoke] planning agent returned no usable steps, using derived suite")
+		return derived
+	}
+	return suite
+}
+
+func smokePlanPrompt(files []VerifiableFile, derived *SmokeSuite) string {
+	var b strings.Builder
+	b.WriteString(`You are writing a browser smoke test suite for a generated web app that is running as a preview.
+Pick the user journeys that prove the app works: open its main routes, sign in when it has a login form, and use its main create flow.
+
+Return ONLY JSON with this shape:
+{"steps": [{"name": "short description", "kind": "visit|login|create", "path": "/route"}]}
+
+Rules:
+- "visit" opens path and checks it renders without runtime errors.
+- "login" opens path and submits the form that has a password field.
+- "create" opens path and submits the first form without a password field.
+- Paths are routes the app serves, starting with "/", without parameters like ":id".
+- At most `)
+	fmt.Fprintf(&b, "%d steps. Start with a visit to \"/\".\n\n", smokeMaxSteps)
+
+	if derived != nil && len(derived.Steps) > 0 {
+		b.WriteString("Routes and forms found by static analysis:\n")
+		for _, step := range derived.Steps {
+			fmt.Fprintf(&b, "- %s %s\n", step.Kind, step.Path)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("Source files:\n")
+	total := 0
+	for _, f := range smokePlanFiles(files) {
+		content := f.Content
+		if len(content) > smokePlanMaxFileBytes {
+			content = content[:smokePlanMaxFileBytes] + "\n// …truncated"
+		}
+		if total+len(content) > smokePlanMaxTotalBytes {
+			break
+		}
+		total += len(content)
+		fmt.Fprintf(&b, "\n--- %s ---\n%s\n", f.Path, content)
+	}
+	return b.String()
+}
+
+// smokePlanFiles orders frontend sources so routing and form code is sent to
+// the planning agent first.
+func smokePlanFiles(files []VerifiableFile) []VerifiableFile {
+	var out []VerifiableFile
+	for _, f := range files {
+		if isSmokeSourceFile(f.Path) {
+			out = append(out, f)
+		}
+	}
+	score := func(f VerifiableFile) int {
+		s := 0
+		if smokeJSXRoutePattern.MatchString(f.Content) || smokeObjectRoutePattern.MatchString(f.Content) {
+			s += 2
+		}
+		if smokeFormPattern.MatchString(f.Content) {
+			s++
+		}
+		return s
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		si, sj := score(out[i]), score(out[j])
+		if si != sj {
+			return si > sj
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// parseSmokePlan validates the planning agent's reply. It returns nil when no
+// step is usable.
+func parseSmokePlan(raw string) *SmokeSuite {
+	object := extractVisionJSONObject(strings.TrimSpace(raw))
+	if object == "" {
+		return nil
+	}
+	var plan SmokeSuite
+	if err := json.Unmarshal([]byte(object), &plan); err != nil {
+		return nil
+	}
+
+	suite := &SmokeSuite{Source: SmokeSourceAgent}
+	seen := map[string]bool{}
+	hasRoot := false
+	for _, step := range plan.Steps {
+		switch step.Kind {
+		case SmokeStepVisit, SmokeStepLogin, SmokeStepCreate:
+		default:
+			continue
+		}
+		path := strings.TrimSpace(step.Path)
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, ":*") {
+			continue
+		}
+		step.Path = normalizeSmokeRoute(path)
+		key := string(step.Kind) + " " + step.Path
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		step.Name = strings.TrimSpace(step.Name)
+		if step.Name == "" {
+			step.Name = key
+		}
+		if step.Kind == SmokeStepVisit && step.Path == "/" {
+			hasRoot = true
+		}
+		suite.Steps = append(suite.Steps, step)
+	}
+	if len(suite.Steps) == 0 {
+		return nil
+	}
+	if !hasRoot {
+		suite.Steps = append([]SmokeStep{{Name: "visit /", Kind: SmokeStepVisit, Path: "/"}}, suite.Steps...)
+	}
+	if len(suite.Steps) > smokeMaxSteps {
+		suite.Steps = suite.Steps[:smokeMaxSteps]
+	}
+	return suite
+}
+
+// DeriveSmokeSuite inspects generated frontend files and returns the journeys
+// worth exercising. The root route is always visited.
+func DeriveSmokeSuite(files []VerifiableFile) *SmokeSuite {
+	routes := map[string]bool{"/": true}
+	hasLogin := false
+	hasCreateForm := false
+
+	for _, f := range files {
+		if !isSmokeSourceFile(f.Path) {
+			continue
+		}
+		for _, m := range smokeJSXRoutePattern.FindAllStringSubmatch(f.Content, -1) {
+			routes[normalizeSmokeRoute(m[1])] = true
+		}
+		for _, m := range smokeObjectRoutePattern.FindAllStringSubmatch(f.Content, -1) {
+			routes[normalizeSmokeRoute(m[1])] = true
+		}
+		if smokePasswordPattern.MatchString(f.Content) {
+			hasLogin = true
+		}
+		if smokeFormPattern.MatchString(f.Content) && !smokePasswordPattern.MatchString(f.Content) {
+			hasCreateForm = true
+		}
+	}
+
+	paths := make([]string, 0, len(routes))
+	for route := range routes {
+		paths = append(paths, route)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i] == "/" || paths[j] == "/" {
+			return paths[i] == "/"
+		}
+		return paths[i] < paths[j]
+	})
+	if len(paths) > smokeMaxVisitSteps {
+		paths = paths[:smokeMaxVisitSteps]
+	}
+
+	suite := &SmokeSuite{Source: SmokeSourceHeuristic}
+	for _, p := range paths {
+		suite.Steps = append(suite.Steps, SmokeStep{Name: "visit " + p, Kind: SmokeStepVisit, Path: p})
+	}
+	if hasLogin {
+		loginPath := firstRouteMatching(routes, "login", "signin", "sign-in", "auth")
+		suite.Steps = append(suite.Steps, SmokeStep{Name: "login flow", Kind: SmokeStepLogin, Path: loginPath})
+	}
+	if hasCreateForm {
+		createPath := firstRouteMatching(routes, "new", "create", "add")
+		suite.Steps = append(suite.Steps, SmokeStep{Name: "create flow", Kind: SmokeStepCreate, Path: createPath})
+	}
+	return suite
+}
+
+func isSmokeSourceFile(path string) bool {
+	lower := strings.ToLower(path)
+	if strings.Contains(lower, "node_modules/") || strings.Contains(lower, ".test.") || strings.Contains(lower, ".spec.") {
+		return false
+	}
+	switch filepath.Ext(lower) {
+	case ".tsx", ".jsx", ".ts", ".js", ".vue", ".svelte":
+		return true
+	default:
+		return false
+	}
+}
+
+func normalizeSmokeRoute(route string) string {
+	route = strings.TrimSpace(route)
+	if route == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	if len(route) > 1 {
+		route = strings.TrimRight(route, "/")
+	}
+	return route
+}
+
+func firstRouteMatching(routes map[string]bool, needles ...string) string {
+	candidates := make([]string, 0, len(routes))
+	for route := range routes {
+		candidates = append(candidates, route)
+	}
+	sort.Strings(candidates)
+	for _, needle := range needles {
+		for _, route := range candidates {
+			if strings.Contains(strings.ToLower(route), needle) {
+				return route
+			}
+		}
+	}
+	return "/"
+}
+
+// RenderPlaywrightSpec renders the suite as a Playwright test file. The spec
+// is stored alongside the smoke artifacts so users can rerun the exact
+// journeys locally with `npx playwright test`.
+func RenderPlaywrightSpec(suite *SmokeSuite) string {
+	var b strings.Builder
+	b.WriteString("// Generated by APEX.BUILD preview validation. Safe to edit.\n")
+	b.WriteString("import { test, expect } from '@playwright/test';\n\n")
+	b.WriteString("const BASE_URL = process.env.APEX_PREVIEW_URL ?? 'http://localhost:5173';\n\n")
+	b.WriteString("async function expectMounted(page) {\n")
+	b.WriteString("  const root = page.locator('#root, #app, #__next').first();\n")
+	b.WriteString("  await expect(root).not.toBeEmpty();\n")
+	b.WriteString("}\n\n")
+	b.WriteString("test.describe('apex smoke', () => {\n")
+	if suite != nil {
+		for _, step := range suite.Steps {
+			fmt.Fprintf(&b, "  test(%q, async ({ page }) => {\n", step.Name)
+			b.WriteString("    const errors: string[] = [];\n")
+			b.WriteString("    page.on('pageerror', (err) => errors.push(err.message));\n")
+			fmt.Fprintf(&b, "    await page.goto(BASE_URL + %q);\n", step.Path)
+			b.WriteString("    await expectMounted(page);\n")
+			switch step.Kind {
+			case SmokeStepLogin:
+				fmt.Fprintf(&b, "    await page.locator('input[type=email], input[name*=email i], input[type=text]').first().fill(%q);\n", smokeTestEmail)
+				fmt.Fprintf(&b, "    await page.locator('input[type=password]').first().fill(%q);\n", smokeTestPassword)
+				b.WriteString("    await page.locator('form').first().evaluate((form: HTMLFormElement) => form.requestSubmit());\n")
+				b.WriteString("    await expectMounted(page);\n")
+			case SmokeStepCreate:
+				fmt.Fprintf(&b, "    for (const input of await page.locator('form input[type=text], form input:not([type]), form textarea').all()) await input.fill(%q);\n", smokeTestText)
+				b.WriteString("    await page.locator('form').first().evaluate((form: HTMLFormElement) => form.requestSubmit());\n")
+				b.WriteString("    await expectMounted(page);\n")
+			}
+			b.WriteString("    expect(errors).toEqual([]);\n")
+			b.WriteString("  });\n")
+		}
+	}
+	b.WriteString("});\n")
+	return b.String()
+}
+
+// smokeActionJS fills and submits the first matching form for login and
+// create journeys. It never follows navigation away from the preview origin.
+const smokeActionJS = `JSON.stringify((function(kind, email, password, text) {
+  var forms = Array.prototype.slice.call(document.querySelectorAll('form'));
+  var form = null;
+  for (var i = 0; i < forms.length; i++) {
+    var hasPassword = !!forms[i].querySelector('input[type=password]');
+    if ((kind === 'login') === hasPassword) { form = forms[i]; break; }
+  }
+  if (!form) return { found: false, submitted: false };
+  var setValue = function(el, value) {
+    var proto = el.tagName === 'TEXTAREA' ? HTMLTextAreaElement.prototype : HTMLInputElement.prototype;
+    var setter = Object.getOwnPropertyDescriptor(proto, 'value').set;
+    setter.call(el, value);
+    el.dispatchEvent(new Event('input', { bubbles: true }));
+    el.dispatchEvent(new Event('change', { bubbles: true }));
+  };
+  var fields = form.querySelectorAll('input, textarea');
+  for (var j = 0; j < fields.length; j++) {
+    var el = fields[j];
+    var type = (el.getAttribute('type') || 'text').toLowerCase();
+    if (el.disabled || el.readOnly) continue;
+    if (type === 'password') setValue(el, password);
+    else if (type === 'email' || /email/i.test(el.name || '')) setValue(el, email);
+    else if (type === 'text' || type === 'search' || el.tagName === 'TEXTAREA') setValue(el, text);
+    else if (type === 'number') setValue(el, '1');
+  }
+  try {
+    if (form.requestSubmit) form.requestSubmit(); else form.dispatchEvent(new Event('submit', { bubbles: true, cancelable: true }));
+    return { found: true, submitted: true };
+  } catch (err) {
+    return { found: true, submitted: false, error: String((err && err.message) || err) };
+  }
+})(%q, %q, %q, %q))`
+
+type smokeActionPayload struct {
+	Found     bool   `json:"found"`
+	Submitted bool   `json:"submitted"`
+	Error     string `json:"error"`
+}
+
+// RunSmokeSuite replays suite against baseURL and records trace artifacts.
+func (sr *SmokeRunner) RunSmokeSuite(ctx context.Context, baseURL string, suite *SmokeSuite) *SmokeResult {
+	start := time.Now()
+	if !sr.Available() || suite == nil || len(suite.Steps) == 0 {
+		return &SmokeResult{Skipped: true, Duration: time.Since(start)}
+	}
+
+	select {
+	case chromeSem <- struct{}{}:
+	case <-ctx.Done():
+		return &SmokeResult{Skipped: true, Duration: time.Since(start)}
+	}
+	defer func() { <-chromeSem }()
+
+	runCtx, cancel := context.WithTimeout(ctx, smokeSuiteTimeout)
+	defer cancel()
+
+	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ExecPath(sr.chromePath),
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("disable-background-networking", true),
+		chromedp.Flag("incognito", true),
+		chromedp.Flag("mute-audio", true),
+		chromedp.Flag("no-first-run", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("proxy-server", "direct://"),
+		chromedp.Flag("proxy-bypass-list", "<-loopback>"),
+		chromedp.WindowSize(1280, 800),
+	)
+	allocCtx, allocCancel := chromedp.NewExecAllocator(runCtx, allocOpts...)
+	defer allocCancel()
+	tabCtx, tabCancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(func(string, ...any) {}))
+	defer tabCancel()
+
+	result := &SmokeResult{Passed: true}
+	pruneSmokeArtifacts(sr.artifactDir, time.Now())
+	runDir := filepath.Join(sr.artifactDir, uuid.New().String())
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		log.Printf("[e2e_smoke] artifact dir unavailable (%v); continuing without frames", err)
+		runDir = ""
+	}
+	result.ArtifactDir = runDir
+
+	if err := chromedp.Run(tabCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(earlyInjection).Do(ctx)
+		return err
+	})); err != nil {
+		return &SmokeResult{Skipped: true, Duration: time.Since(start), Errors: []string{fmt.Sprintf("smoke browser launch failed: %v", err)}}
+	}
+
+	root := strings.TrimRight(baseURL, "/")
+	for i, step := range suite.Steps {
+		stepResult := sr.runStep(tabCtx, root, i, step, start, runDir, result)
+		result.Steps = append(result.Steps, stepResult)
+		if !stepResult.Passed {
+			result.Passed = false
+			for _, e := range stepResult.Errors {
+				result.Errors = appendUniqueStrings(result.Errors, fmt.Sprintf("%s: %s", step.Name, e))
+			}
+		}
+	}
+
+	if !result.Passed {
+		result.RepairHints = smokeRepairHints(result.Steps)
+	}
+	result.Duration = time.Since(start)
+	sr.writeArtifacts(runDir, suite, result)
+	emitSmokeTelemetry(baseURL, result)
+	return result
+}
+
+// pruneSmokeArtifacts removes run directories under root that are older than
+// smokeArtifactRetention, then all but the newest smokeArtifactMaxRuns. Only
+// directories named like a run are touched.
+func pruneSmokeArtifacts(root string, now time.Time) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	type run struct {
+		path    string
+		modTime time.Time
+	}
+	var runs []run
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := uuid.Parse(entry.Name()); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		runs = append(runs, run{path: filepath.Join(root, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].modTime.After(runs[j].modTime) })
+	for i, r := range runs {
+		if i < smokeArtifactMaxRuns && now.Sub(r.modTime) < smokeArtifactRetention {
+			continue
+		}
+		if err := os.RemoveAll(r.path); err != nil {
+			log.Printf("[e2e_smoke] failed to prune %s: %v", r.path, err)
+		}
+	}
+}
+
+func (sr *SmokeRunner) runStep(tabCtx context.Context, root string, index int, step SmokeStep, suiteStart time.Time, runDir string, result *SmokeResult) SmokeStepResult {
+	stepStart := time.Now()
+	out := SmokeStepResult{Step: step, Passed: true}
+	trace := func(action, status, detail, frame string) {
+		result.Trace = append(result.Trace, SmokeTraceEvent{
+			Step:      step.Name,
+			Action:    action,
+			Status:    status,
+			Detail:    detail,
+			OffsetMS:  time.Since(suiteStart).Milliseconds(),
+			FramePath: frame,
+		})
+	}
+	fail := func(action, detail string) {
+		out.Passed = false
+		out.Errors = append(out.Errors, detail)
+		trace(action, "failed", detail, "")
+	}
+
+	stepCtx, cancel := context.WithTimeout(tabCtx, smokeStepTimeout)
+	defer cancel()
+
+	var mountJSON string
+	if err := chromedp.Run(stepCtx,
+		chromedp.Navigate(root+step.Path),
+		chromedp.ActionFunc(func(ctx context.Context) error { return pollBrowserMountContent(ctx, &mountJSON) }),
+	); err != nil {
+		fail("navigate", fmt.Sprintf("navigation to %s failed: %v", step.Path, err))
+		out.Duration = time.Since(stepStart)
+		return out
+	}
+	if !browserMountJSONHasContent(mountJSON) {
+		fail("mount", fmt.Sprintf("%s rendered an empty page", step.Path))
+	} else {
+		trace("navigate", "passed", step.Path, "")
+	}
+
+	var baselineJSON string
+	_ = chromedp.Run(stepCtx, chromedp.Evaluate(canaryRuntimeErrorsJS, &baselineJSON))
+
+	if out.Passed && (step.Kind == SmokeStepLogin || step.Kind == SmokeStepCreate) {
+		var actionJSON string
+		script := fmt.Sprintf(smokeActionJS, string(step.Kind), smokeTestEmail, smokeTestPassword, smokeTestText)
+		if err := chromedp.Run(stepCtx, chromedp.Evaluate(script, &actionJSON), chromedp.Sleep(smokeSettleDelay)); err != nil {
+			fail("submit", fmt.Sprintf("form submission failed: %v", err))
+		} else {
+			var action smokeActionPayload
+			_ = json.Unmarshal([]byte(actionJSON), &action)
+			switch {
+			case !action.Found:
+				fail("submit", fmt.Sprintf("no %s form found on %s", step.Kind, step.Path))
+			case !action.Submitted:
+				fail("submit", fmt.Sprintf("%s form could not be submitted: %s", step.Kind, action.Error))
+			default:
+				trace("submit", "passed", string(step.Kind)+" form submitted", "")
+				if err := chromedp.Run(stepCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+					return pollBrowserMountContent(ctx, &mountJSON)
+				})); err != nil || !browserMountJSONHasContent(mountJSON) {
+					fail("settle", fmt.Sprintf("preview stopped rendering after the %s flow", step.Kind))
+				}
+			}
+		}
+	}
+
+	var postJSON string
+	if err := chromedp.Run(stepCtx, chromedp.Evaluate(canaryRuntimeErrorsJS, &postJSON)); err == nil {
+		var baseline, post canaryRuntimeErrorPayload
+		_ = json.Unmarshal([]byte(baselineJSON), &baseline)
+		_ = json.Unmarshal([]byte(postJSON), &post)
+		newErrors := subtractStringMultiset(filterBrowserNoise(post.Errors), filterBrowserNoise(baseline.Errors))
+		if step.Kind == SmokeStepVisit {
+			newErrors = filterBrowserNoise(post.Errors)
+		}
+		for _, e := range newErrors {
+			fail("runtime_error", e)
+		}
+	}
+
+	framePath := ""
+	if runDir != "" {
+		var frame []byte
+		if err := chromedp.Run(stepCtx, chromedp.CaptureScreenshot(&frame)); err == nil && len(frame) > 0 {
+			framePath = filepath.Join(runDir, fmt.Sprintf("step-%02d.png", index+1))
+			if err := os.WriteFile(framePath, frame, 0o644); err != nil {
+				framePath = ""
+			}
+		}
+	}
+	status := "passed"
+	if !out.Passed {
+		status = "failed"
+	}
+	trace("complete", status, "", framePath)
+	out.Duration = time.Since(stepStart)
+	return out
+}
+
+func smokeRepairHints(steps []SmokeStepResult) []string {
+	var hints []string
+	for _, s := range steps {
+		if s.Passed {
+			continue
+		}
+		switch s.Step.Kind {
+		case SmokeStepLogin:
+			hints = append(hints, fmt.Sprintf("The login form on %s must submit without runtime errors and keep the app rendered (show an inline error when the backend rejects credentials).", s.Step.Path))
+		case SmokeStepCreate:
+			hints = append(hints, fmt.Sprintf("The create form on %s must accept input, submit without runtime errors, and keep the list/detail view rendered afterwards.", s.Step.Path))
+		default:
+			hints = append(hints, fmt.Sprintf("Route %s must render visible content without uncaught errors when opened directly.", s.Step.Path))
+		}
+	}
+	return compactNonEmptyStrings(hints)
+}
+
+func (sr *SmokeRunner) writeArtifacts(runDir string, suite *SmokeSuite, result *SmokeResult) {
+	if runDir == "" {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(runDir, "apex-smoke.spec.ts"), []byte(RenderPlaywrightSpec(suite)), 0o644); err != nil {
+		log.Printf("[e2e_smoke] failed to write spec artifact: %v", err)
+	}
+	trace, err := json.MarshalIndent(map[string]any{
+		"source":      suite.Source,
+		"passed":      result.Passed,
+		"duration_ms": result.Duration.Milliseconds(),
+		"steps":       result.Steps,
+		"trace":       result.Trace,
+		"errors":      result.Errors,
+	}, "", "  ")
+	if err == nil {
+		if err := os.WriteFile(filepath.Join(runDir, "trace.json"), trace, 0o644); err != nil {
+			log.Printf("[e2e_smoke] failed to write trace artifact: %v", err)
+		}
+	}
+}
+
+func emitSmokeTelemetry(pageURL string, result *SmokeResult) {
+	if result == nil {
+		return
+	}
+	data, err := json.Marshal(map[string]any{
+		"page_url":     strings.TrimSpace(pageURL),
+		"passed":       result.Passed,
+		"steps":        len(result.Steps),
+		"error_count":  len(result.Errors),
+		"artifact_dir": result.ArtifactDir,
+		"duration_ms":  result.Duration.Milliseconds(),
+	})
+	if err != nil {
+		return
+	}
+	log.Printf("[e2e_smoke] %s", string(data))
+}
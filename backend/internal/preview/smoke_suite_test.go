@@ -0,0 +1,217 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/ai"
+
+	"github.com/google/uuid"
+)
+
+func TestDeriveSmokeSuiteCollectsRoutesLoginAndCreateFlows(t *testing.T) {
+	suite := DeriveSmokeSuite([]VerifiableFile{
+		{Path: "src/App.tsx", Content: `
+			<Routes>
+				<Route path="/" element={<Home />} />
+				<Route path="/login" element={<Login />} />
+				<Route path="/tasks/:id" element={<TaskDetail />} />
+				<Route path="/tasks/new" element={<NewTask />} />
+			</Routes>`},
+		{Path: "src/pages/Login.tsx", Content: `<form onSubmit={submit}><input type="password" /></form>`},
+		{Path: "src/pages/NewTask.tsx", Content: `<form onSubmit={create}><input name="title" /></form>`},
+		{Path: "src/pages/NewTask.test.tsx", Content: `<Route path="/ignored" />`},
+	})
+
+	var visits []string
+	var login, create *SmokeStep
+	for i := range suite.Steps {
+		step := suite.Steps[i]
+		switch step.Kind {
+		case SmokeStepVisit:
+			visits = append(visits, step.Path)
+		case SmokeStepLogin:
+			login = &suite.Steps[i]
+		case SmokeStepCreate:
+			create = &suite.Steps[i]
+		}
+	}
+
+	if got := strings.Join(visits, ","); got != "/,/login,/tasks/new" {
+		t.Fatalf("visit steps = %q, want /,/login,/tasks/new", got)
+	}
+	if login == nil || login.Path != "/login" {
+		t.Fatalf("expected login step on /login, got %+v", login)
+	}
+	if create == nil || create.Path != "/tasks/new" {
+		t.Fatalf("expected create step on /tasks/new, got %+v", create)
+	}
+}
+
+func TestDeriveSmokeSuiteDefaultsToRootVisit(t *testing.T) {
+	suite := DeriveSmokeSuite([]VerifiableFile{{Path: "src/App.tsx", Content: "export default function App() { return <main>Hi</main> }"}})
+	if len(suite.Steps) != 1 || suite.Steps[0].Kind != SmokeStepVisit || suite.Steps[0].Path != "/" {
+		t.Fatalf("expected a single root visit, got %+v", suite.Steps)
+	}
+}
+
+func TestRenderPlaywrightSpecIncludesEveryStep(t *testing.T) {
+	spec := RenderPlaywrightSpec(&SmokeSuite{Steps: []SmokeStep{
+		{Name: "visit /", Kind: SmokeStepVisit, Path: "/"},
+		{Name: "login flow", Kind: SmokeStepLogin, Path: "/login"},
+	}})
+	for _, want := range []string{"@playwright/test", `test("visit /"`, `test("login flow"`, "input[type=password]", "requestSubmit"} {
+		if !strings.Contains(spec, want) {
+			t.Fatalf("spec missing %q:\n%s", want, spec)
+		}
+	}
+}
+
+func TestE2ESmokeModeFromEnv(t *testing.T) {
+	cases := map[string]SmokeMode{"": SmokeModeOff, "advisory": SmokeModeAdvisory, "false": SmokeModeOff, "on": SmokeModeEnforce, "enforce": SmokeModeEnforce}
+	for raw, want := range cases {
+		t.Setenv("APEX_E2E_SMOKE", raw)
+		if got := E2ESmokeMode(); got != want {
+			t.Fatalf("APEX_E2E_SMOKE=%q: got %q, want %q", raw, got, want)
+		}
+	}
+}
+
+type stubSmokeRunner struct {
+	mode   SmokeMode
+	result *SmokeResult
+}
+
+func (s *stubSmokeRunner) Available() bool { return true }
+func (s *stubSmokeRunner) Mode() SmokeMode { return s.mode }
+func (s *stubSmokeRunner) RunSmokeSuite(context.Context, string, *SmokeSuite) *SmokeResult {
+	return s.result
+}
+
+func TestRuntimeVerifierSmokeFailureBlocksInEnforceMode(t *testing.T) {
+	failing := &SmokeResult{
+		Steps:       []SmokeStepResult{{Step: SmokeStep{Name: "login flow", Kind: SmokeStepLogin, Path: "/login"}}},
+		Errors:      []string{"login flow: TypeError: user is undefined"},
+		RepairHints: []string{"fix login"},
+		ArtifactDir: "/tmp/smoke-run",
+	}
+
+	rv := &RuntimeVerifier{smoke: &stubSmokeRunner{mode: SmokeModeEnforce, result: failing}}
+	failed := rv.runSmokeSuite(context.Background(), &RuntimeVerificationResult{Passed: true}, "http://127.0.0.1:1", nil, time.Now(), "")
+	if failed == nil || failed.Passed || failed.FailureKind != "smoke_failed" {
+		t.Fatalf("expected smoke_failed result, got %+v", failed)
+	}
+	if failed.SmokeStepsRun != 1 {
+		t.Fatalf("expected smoke steps to be propagated, got %+v", failed)
+	}
+
+	advisory := &RuntimeVerificationResult{Passed: true}
+	rv = &RuntimeVerifier{smoke: &stubSmokeRunner{mode: SmokeModeAdvisory, result: failing}}
+	if res := rv.runSmokeSuite(context.Background(), advisory, "http://127.0.0.1:1", nil, time.Now(), ""); res != nil {
+		t.Fatalf("advisory mode must not fail verification, got %+v", res)
+	}
+	if len(advisory.RepairHints) == 0 || !strings.HasPrefix(advisory.RepairHints[0], "smoke:") {
+		t.Fatalf("expected smoke-prefixed advisory hints, got %v", advisory.RepairHints)
+	}
+}
+
+type stubSmokeGenerator struct {
+	content string
+	err     error
+	prompt  string
+}
+
+func (s *stubSmokeGenerator) Generate(_ context.Context, req *ai.AIRequest) (*ai.AIResponse, error) {
+	s.prompt = req.Prompt
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &ai.AIResponse{Content: s.content}, nil
+}
+
+func TestSmokePlannerUsesAgentSuite(t *testing.T) {
+	files := []VerifiableFile{
+		{Path: "src/App.tsx", Content: `<Route path="/login" element={<Login />} />`},
+		{Path: "src/pages/Login.tsx", Content: `<form onSubmit={submit}><input type="password" /></form>`},
+	}
+	generator := &stubSmokeGenerator{content: "Here is the suite:\n" + `{"steps": [
+		{"name": "sign in", "kind": "login", "path": "/login"},
+		{"name": "open a task", "kind": "visit", "path": "/tasks/:id"},
+		{"name": "delete everything", "kind": "script", "path": "/"},
+		{"name": "dashboard", "kind": "visit", "path": "/dashboard/"}
+	]}`}
+
+	suite := NewSmokePlanner(generator).PlanSmokeSuite(context.Background(), files)
+	if suite.Source != SmokeSourceAgent {
+		t.Fatalf("expected the agent suite, got %+v", suite)
+	}
+	var got []string
+	for _, step := range suite.Steps {
+		got = append(got, string(step.Kind)+" "+step.Path)
+	}
+	if strings.Join(got, ",") != "visit /,login /login,visit /dashboard" {
+		t.Fatalf("steps = %v", got)
+	}
+	if !strings.Contains(generator.prompt, "src/App.tsx") || !strings.Contains(generator.prompt, "login /login") {
+		t.Fatalf("prompt is missing the source or derived routes:\n%s", generator.prompt)
+	}
+
+	// Unavailable or unusable plans fall back to the derived suite
+	for _, g := range []*stubSmokeGenerator{{err: errors.New("no key")}, {content: `{"steps": []}`}, {content: "not json"}} {
+		if suite := NewSmokePlanner(g).PlanSmokeSuite(context.Background(), files); suite.Source != SmokeSourceHeuristic {
+			t.Fatalf("expected the derived suite, got %+v", suite)
+		}
+	}
+	var planner *SmokePlanner
+	if suite := planner.PlanSmokeSuite(context.Background(), files); suite.Source != SmokeSourceHeuristic {
+		t.Fatalf("a nil planner must derive the suite, got %+v", suite)
+	}
+}
+
+func TestPruneSmokeArtifactsKeepsRecentRuns(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	mkRun := func(age time.Duration) string {
+		dir := filepath.Join(root, uuid.New().String())
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	stale := mkRun(smokeArtifactRetention + time.Hour)
+	var recent []string
+	for i := 0; i < smokeArtifactMaxRuns+2; i++ {
+		recent = append(recent, mkRun(time.Duration(i)*time.Minute))
+	}
+	other := filepath.Join(root, "keep-me")
+	if err := os.MkdirAll(other, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pruneSmokeArtifacts(root, now)
+
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	if exists(stale) {
+		t.Fatal("runs past the retention window must be removed")
+	}
+	if !exists(recent[0]) || !exists(recent[smokeArtifactMaxRuns-1]) {
+		t.Fatal("the newest runs must be kept")
+	}
+	if exists(recent[smokeArtifactMaxRuns]) || exists(recent[smokeArtifactMaxRuns+1]) {
+		t.Fatal("runs beyond the newest must be removed")
+	}
+	if !exists(other) {
+		t.Fatal("directories that are not runs must be left alone")
+	}
+}
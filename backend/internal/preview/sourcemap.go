@@ -0,0 +1,167 @@
+// Package preview - Source map decoding for preview diagnostics
+package preview
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// errInvalidSourceMap is returned for source maps that cannot be decoded
+var errInvalidSourceMap = errors.New("invalid source map")
+
+// sourceMap is a decoded version 3 source map
+type sourceMap struct {
+	// sources are the original file paths, relative to the project root
+	sources []string
+	// names are the original identifiers
+	names []string
+	// lines holds each generated line's mappings, ordered by column
+	lines [][]sourceMapping
+}
+
+// sourceMapping maps a generated column to a position in an original file.
+// Positions are zero-based, as in the source map format.
+type sourceMapping struct {
+	genColumn int
+	source    int
+	line      int
+	column    int
+	name      int
+}
+
+// OriginalPosition is where a generated position came from
+type OriginalPosition struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Name   string `json:"name,omitempty"`
+}
+
+// base64VLQ maps base64 characters to their values
+var base64VLQ = func() [256]int {
+	var table [256]int
+	for i := range table {
+		table[i] = -1
+	}
+	for i, c := range "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/" {
+		table[c] = i
+	}
+	return table
+}()
+
+// parseSourceMap decodes a version 3 source map
+func parseSourceMap(data []byte) (*sourceMap, error) {
+	var raw struct {
+		Version    int      `json:"version"`
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+		Names      []string `json:"names"`
+		Mappings   string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || raw.Version != 3 {
+		return nil, errInvalidSourceMap
+	}
+
+	m := &sourceMap{names: raw.Names, sources: make([]string, len(raw.Sources))}
+	for i, source := range raw.Sources {
+		m.sources[i] = cleanSourcePath(raw.SourceRoot, source)
+	}
+
+	// Source, line, column and name are deltas across the whole map; the
+	// generated column restarts on every line
+	var source, line, column, name int
+	for _, encodedLine := range strings.Split(raw.Mappings, ";") {
+		var mappings []sourceMapping
+		genColumn := 0
+		for _, segment := range strings.Split(encodedLine, ",") {
+			if segment == "" {
+				continue
+			}
+			fields, err := decodeVLQSegment(segment)
+			if err != nil {
+				return nil, err
+			}
+			genColumn += fields[0]
+			if len(fields) < 4 {
+				continue
+			}
+			source += fields[1]
+			line += fields[2]
+			column += fields[3]
+			mapping := sourceMapping{genColumn: genColumn, source: source, line: line, column: column, name: -1}
+			if len(fields) >= 5 {
+				name += fields[4]
+				mapping.name = name
+			}
+			if source < 0 || source >= len(m.sources) {
+				return nil, errInvalidSourceMap
+			}
+			mappings = append(mappings, mapping)
+		}
+		sort.SliceStable(mappings, func(i, j int) bool { return mappings[i].genColumn < mappings[j].genColumn })
+		m.lines = append(m.lines, mappings)
+	}
+	return m, nil
+}
+
+// decodeVLQSegment decodes the base64 VLQ fields of one mapping segment
+func decodeVLQSegment(segment string) ([]int, error) {
+	var fields []int
+	value, shift := 0, 0
+	for i := 0; i < len(segment); i++ {
+		digit := base64VLQ[segment[i]]
+		if digit < 0 {
+			return nil, errInvalidSourceMap
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 != 0 {
+			fields = append(fields, -(value >> 1))
+		} else {
+			fields = append(fields, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 || len(fields) == 0 {
+		return nil, errInvalidSourceMap
+	}
+	return fields, nil
+}
+
+// lookup returns the original position of a generated position. Line and
+// column are one-based, as browsers report them in stack traces.
+func (m *sourceMap) lookup(line, column int) (OriginalPosition, bool) {
+	if line < 1 || line > len(m.lines) {
+		return OriginalPosition{}, false
+	}
+	mappings := m.lines[line-1]
+	i := sort.Search(len(mappings), func(i int) bool { return mappings[i].genColumn > column-1 })
+	if i == 0 {
+		return OriginalPosition{}, false
+	}
+	mapping := mappings[i-1]
+	pos := OriginalPosition{Source: m.sources[mapping.source], Line: mapping.line + 1, Column: mapping.column + 1}
+	if mapping.name >= 0 && mapping.name < len(m.names) {
+		pos.Name = m.names[mapping.name]
+	}
+	return pos, true
+}
+
+// cleanSourcePath turns a source path, which esbuild writes relative to the
+// output directory, into a project path
+func cleanSourcePath(root, source string) string {
+	if root != "" && !strings.Contains(source, "://") {
+		source = strings.TrimSuffix(root, "/") + "/" + source
+	}
+	if strings.Contains(source, "://") {
+		return source
+	}
+	cleaned := path.Clean("/" + source)
+	return strings.TrimPrefix(cleaned, "/")
+}
@@ -40,6 +40,7 @@ type VerificationResult struct {
 	CanaryPostInteractionChecked bool   // true when the canary completed the post-click settle check
 	CanaryPostInteractionHealthy bool   // false when the preview blanked after interactions
 	VisionSeverity               string // "critical", "advisory", "clean", or "" when vision skipped
+	SmokeStepsRun                int    // generated E2E smoke steps executed during runtime proof
 }
 
 // CheckResult records the outcome of a single named check.
@@ -289,6 +290,7 @@ func (v *Verifier) VerifyFiles(ctx context.Context, files []VerifiableFile, isFu
 				res.CanaryPostInteractionChecked = rr.CanaryPostInteractionChecked
 				res.CanaryPostInteractionHealthy = rr.CanaryPostInteractionHealthy
 				res.VisionSeverity = rr.VisionSeverity
+				res.SmokeStepsRun = rr.SmokeStepsRun
 				if len(rr.ScreenshotData) > 0 {
 					res.ScreenshotBase64 = base64.StdEncoding.EncodeToString(rr.ScreenshotData)
 				}
@@ -320,6 +322,7 @@ func (v *Verifier) VerifyFiles(ctx context.Context, files []VerifiableFile, isFu
 				failed.RepairHints = appendUniqueStrings(failed.RepairHints, hints...)
 				failed.CanaryErrors = appendUniqueStrings(failed.CanaryErrors, rr.CanaryErrors...)
 				failed.CanaryClickCount = rr.CanaryClickCount
+				failed.SmokeStepsRun = rr.SmokeStepsRun
 				return failed
 			}
 		}
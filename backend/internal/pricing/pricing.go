@@ -297,6 +297,12 @@ func (e *Engine) EstimateCost(provider, model string, promptChars int, maxTokens
 	return e.BilledCost(provider, model, inputTokens, maxTokens, powerMode, isBYOK)
 }
 
+// ModelRates returns the per-1M token list price for a model, falling back
+// to the provider default for unknown models.
+func (e *Engine) ModelRates(provider, model string) ModelPricing {
+	return e.modelPricing(provider, model)
+}
+
 // ProfitMargin returns the current profit margin multiplier.
 func (e *Engine) ProfitMargin() float64 {
 	return e.profitMargin
@@ -426,7 +432,7 @@ func normalizePowerMode(mode string, fallback string) string {
 		return ModeMax
 	case "balanced", "balance":
 		return ModeBalanced
-	case "fast", "cheap", "economy":
+	case "fast", "cheap", "economy", "budget":
 		return ModeFast
 	case "auto":
 		return ModeAuto
@@ -0,0 +1,214 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+)
+
+// accountExport is the profile section of an export. It lists the fields a
+// user can see about themselves and leaves out credentials.
+type accountExport struct {
+	ID                 uint       `json:"id"`
+	Username           string     `json:"username"`
+	Email              string     `json:"email"`
+	FullName           string     `json:"full_name"`
+	AvatarURL          string     `json:"avatar_url"`
+	CreatedAt          time.Time  `json:"created_at"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty"`
+	SubscriptionType   string     `json:"subscription_type"`
+	SubscriptionStatus string     `json:"subscription_status"`
+	CreditBalance      float64    `json:"credit_balance"`
+	PreferredTheme     string     `json:"preferred_theme"`
+	PreferredAI        string     `json:"preferred_ai"`
+	ExportedAt         time.Time  `json:"exported_at"`
+}
+
+// tableExport dumps rows from an optional table. Columns are listed
+// explicitly where the table also stores credentials or encrypted values.
+type tableExport struct {
+	name    string
+	table   string
+	columns string
+	where   string
+}
+
+var tableExports = []tableExport{
+	{"ai_usage_logs.json", "ai_usage_logs", "*", "user_id = ?"},
+	{"spend_events.json", "spend_events", "*", "user_id = ?"},
+	{"completion_events.json", "completion_events", "*", "user_id = ?"},
+	{"completion_settings.json", "completion_settings", "*", "user_id = ?"},
+	{"notifications.json", "notifications", "*", "user_id = ?"},
+	{"git_issue_builds.json", "git_issue_builds", "*", "user_id = ?"},
+	{"dependency_update_policies.json", "dependency_update_policies", "*", "user_id = ?"},
+	{"dependency_update_runs.json", "dependency_update_runs", "*", "user_id = ?"},
+	{"build_outcomes.json", "build_outcomes", "*", "user_id = ?"},
+	{"support_bundles.json", "support_bundles", "id, created_at, kind, target_id, project_id, target_status, summary, token_prefix, size_bytes, expires_at, revoked_at, download_count", "user_id = ?"},
+	{"deployments.json", "deployments", "id, created_at, project_id, provider, status, url, environment, branch, commit_sha", "user_id = ?"},
+	{"secrets.json", "secrets", "id, created_at, updated_at, project_id, name, description, type", "user_id = ?"},
+	{"api_keys.json", "user_api_keys", "id, created_at, provider, project_id, model_preference, is_active, usage_count, total_cost", "user_id = ? AND deleted_at IS NULL"},
+}
+
+// Export writes a zip archive with the user's profile, every project and its
+// files, AI request history, builds, audit logs, and billing/usage records.
+func (s *Service) Export(ctx context.Context, userID uint, w io.Writer) error {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	db := s.db.WithContext(ctx)
+	zw := zip.NewWriter(w)
+
+	if err := writeJSON(zw, "account.json", accountExport{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Email:              user.Email,
+		FullName:           user.FullName,
+		AvatarURL:          user.AvatarURL,
+		CreatedAt:          user.CreatedAt,
+		EmailVerifiedAt:    user.EmailVerifiedAt,
+		SubscriptionType:   user.SubscriptionType,
+		SubscriptionStatus: user.SubscriptionStatus,
+		CreditBalance:      user.CreditBalance,
+		PreferredTheme:     user.PreferredTheme,
+		PreferredAI:        user.PreferredAI,
+		ExportedAt:         s.now(),
+	}); err != nil {
+		return err
+	}
+
+	var projects []models.Project
+	if err := db.Where("owner_id = ?", userID).Order("id").Find(&projects).Error; err != nil {
+		return fmt.Errorf("privacy: load projects failed: %w", err)
+	}
+	for _, project := range projects {
+		if err := s.exportProject(ctx, zw, project); err != nil {
+			return err
+		}
+	}
+
+	var aiRequests []models.AIRequest
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&aiRequests).Error; err != nil {
+		return fmt.Errorf("privacy: load AI history failed: %w", err)
+	}
+	if err := writeJSON(zw, "ai_requests.json", aiRequests); err != nil {
+		return err
+	}
+
+	var builds []models.CompletedBuild
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&builds).Error; err != nil {
+		return fmt.Errorf("privacy: load builds failed: %w", err)
+	}
+	if err := writeJSON(zw, "builds.json", builds); err != nil {
+		return err
+	}
+
+	if db.Migrator().HasTable(&enterprise.AuditLog{}) {
+		var logs []enterprise.AuditLog
+		if err := db.Where("user_id = ?", userID).Order("id").Find(&logs).Error; err != nil {
+			return fmt.Errorf("privacy: load audit logs failed: %w", err)
+		}
+		if err := writeJSON(zw, "audit_logs.json", logs); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tableExports {
+		if !db.Migrator().HasTable(t.table) {
+			continue
+		}
+		var rows []map[string]interface{}
+		if err := db.Table(t.table).Select(t.columns).Where(t.where, userID).Order("id").Find(&rows).Error; err != nil {
+			return fmt.Errorf("privacy: load %s failed: %w", t.table, err)
+		}
+		if err := writeJSON(zw, t.name, rows); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("privacy: finalize export failed: %w", err)
+	}
+	return nil
+}
+
+// exportProject writes projects/<id>/project.json plus each file's content.
+// Archived projects include their cold-storage tarball instead.
+func (s *Service) exportProject(ctx context.Context, zw *zip.Writer, project models.Project) error {
+	dir := fmt.Sprintf("projects/%d", project.ID)
+	if err := writeJSON(zw, dir+"/project.json", project); err != nil {
+		return err
+	}
+
+	if project.IsArchived && project.ArchiveKey != "" && s.store != nil {
+		reader, _, err := s.store.Get(ctx, project.ArchiveKey)
+		if err != nil {
+			return fmt.Errorf("privacy: read archive for project %d failed: %w", project.ID, err)
+		}
+		defer reader.Close()
+		entry, err := zw.Create(dir + "/archive.tar.gz")
+		if err != nil {
+			return fmt.Errorf("privacy: write archive for project %d failed: %w", project.ID, err)
+		}
+		if _, err := io.Copy(entry, reader); err != nil {
+			return fmt.Errorf("privacy: write archive for project %d failed: %w", project.ID, err)
+		}
+		return nil
+	}
+
+	var files []models.File
+	if err := s.db.WithContext(ctx).Where("project_id = ? AND type <> ?", project.ID, "directory").Order("path").Find(&files).Error; err != nil {
+		return fmt.Errorf("privacy: load files for project %d failed: %w", project.ID, err)
+	}
+	for _, f := range files {
+		entry, err := zw.Create(path.Join(dir, "files", path.Clean("/"+f.Path)))
+		if err != nil {
+			return fmt.Errorf("privacy: write %s failed: %w", f.Path, err)
+		}
+		if f.IsBinary {
+			if err := s.copyBlob(ctx, entry, f.Hash); err != nil {
+				return fmt.Errorf("privacy: write %s failed: %w", f.Path, err)
+			}
+			continue
+		}
+		if _, err := io.WriteString(entry, f.Content); err != nil {
+			return fmt.Errorf("privacy: write %s failed: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// copyBlob writes a binary file's bytes from the blob store.
+func (s *Service) copyBlob(ctx context.Context, w io.Writer, hash string) error {
+	if s.store == nil || !storage.ValidBlobHash(hash) {
+		return fmt.Errorf("blob %q unavailable", hash)
+	}
+	reader, _, err := s.store.Get(ctx, storage.BlobKey(hash))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	entry, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("privacy: write %s failed: %w", name, err)
+	}
+	enc := json.NewEncoder(entry)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("privacy: encode %s failed: %w", name, err)
+	}
+	return nil
+}
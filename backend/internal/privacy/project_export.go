@@ -0,0 +1,345 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Project export statuses.
+const (
+	ProjectExportQueued    = "queued"
+	ProjectExportRunning   = "running"
+	ProjectExportCompleted = "completed"
+	ProjectExportFailed    = "failed"
+	ProjectExportExpired   = "expired"
+)
+
+const (
+	// ProjectExportInterval is how often a user may request a project export.
+	// Failed exports do not count.
+	ProjectExportInterval = 24 * time.Hour
+	// ProjectExportTTL is how long a finished archive can be downloaded.
+	ProjectExportTTL = 7 * 24 * time.Hour
+	// projectExportStaleAfter fails exports left running by a restart.
+	projectExportStaleAfter = time.Hour
+)
+
+var (
+	// ErrExportUnavailable is returned when no artifact store is configured.
+	ErrExportUnavailable = errors.New("project exports are not available")
+	// ErrExportRateLimited is returned when the user exported in the last
+	// ProjectExportInterval.
+	ErrExportRateLimited = errors.New("a project export was already requested in the last 24 hours")
+	// ErrExportNotFound is returned for unknown exports.
+	ErrExportNotFound = errors.New("project export not found")
+	// ErrExportNotReady is returned when downloading an export that has not
+	// completed or has expired.
+	ErrExportNotReady = errors.New("project export is not available for download")
+)
+
+// ProjectExport is an asynchronous backup of every project a user owns. The
+// archive lives in the artifact store until ExpiresAt.
+type ProjectExport struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Status      string     `json:"status" gorm:"size:20;not null;index"`
+	Projects    int        `json:"projects"`
+	Files       int        `json:"files"`
+	SizeBytes   int64      `json:"size_bytes"`
+	ArtifactKey string     `json:"-" gorm:"size:255"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`
+}
+
+// TableName keeps the table name explicit.
+func (ProjectExport) TableName() string { return "project_exports" }
+
+// projectExportManifest is manifest.json at the root of a project export.
+type projectExportManifest struct {
+	UserID     uint                    `json:"user_id"`
+	ExportedAt time.Time               `json:"exported_at"`
+	Projects   []projectManifestRecord `json:"projects"`
+}
+
+type projectManifestRecord struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Files    int    `json:"files"`
+	Archived bool   `json:"archived"`
+}
+
+// projectEnvNames lists the environment variable names a project uses.
+// Values are never exported.
+type projectEnvNames struct {
+	Project      []string            `json:"project"`
+	Secrets      []string            `json:"secrets,omitempty"`
+	Environments map[string][]string `json:"environments,omitempty"`
+	Deployment   []string            `json:"deployment,omitempty"`
+}
+
+// RequestProjectExport queues an export of all of the user's projects. When
+// the user already exported within ProjectExportInterval the earlier export
+// is returned with ErrExportRateLimited.
+func (s *Service) RequestProjectExport(ctx context.Context, userID uint) (*ProjectExport, error) {
+	if s.store == nil {
+		return nil, ErrExportUnavailable
+	}
+	if _, err := s.loadUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	s.exportMu.Lock()
+	defer s.exportMu.Unlock()
+	var recent ProjectExport
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND status <> ? AND created_at > ?", userID, ProjectExportFailed, s.now().Add(-ProjectExportInterval)).
+		Order("id DESC").First(&recent).Error
+	if err == nil {
+		return &recent, ErrExportRateLimited
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("privacy: load recent exports failed: %w", err)
+	}
+
+	export := ProjectExport{UserID: userID, Status: ProjectExportQueued, CreatedAt: s.now()}
+	if err := s.db.WithContext(ctx).Create(&export).Error; err != nil {
+		return nil, fmt.Errorf("privacy: create project export failed: %w", err)
+	}
+	job := export
+	s.launch(func() { s.runProjectExport(context.Background(), &job) })
+	return &export, nil
+}
+
+// ListProjectExports returns the user's exports, newest first.
+func (s *Service) ListProjectExports(ctx context.Context, userID uint) ([]ProjectExport, error) {
+	var exports []ProjectExport
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Limit(20).Find(&exports).Error; err != nil {
+		return nil, fmt.Errorf("privacy: list project exports failed: %w", err)
+	}
+	return exports, nil
+}
+
+// OpenProjectExport returns a reader for a completed export's archive. The
+// caller must close it.
+func (s *Service) OpenProjectExport(ctx context.Context, userID, exportID uint) (*ProjectExport, io.ReadCloser, int64, error) {
+	var export ProjectExport
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, 0, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("privacy: load project export failed: %w", err)
+	}
+	if export.Status != ProjectExportCompleted || s.store == nil ||
+		(export.ExpiresAt != nil && s.now().After(*export.ExpiresAt)) {
+		return &export, nil, 0, ErrExportNotReady
+	}
+	reader, size, err := s.store.Get(ctx, export.ArtifactKey)
+	if err != nil {
+		return &export, nil, 0, fmt.Errorf("privacy: read project export failed: %w", err)
+	}
+	return &export, reader, size, nil
+}
+
+// runProjectExport builds the archive in a temporary file, uploads it to the
+// artifact store and emails the user.
+func (s *Service) runProjectExport(ctx context.Context, export *ProjectExport) {
+	s.db.WithContext(ctx).Model(export).Update("status", ProjectExportRunning)
+
+	fail := func(err error) {
+		log.Printf("privacy: project export %d for user %d failed: %v", export.ID, export.UserID, err)
+		s.db.WithContext(ctx).Model(export).Updates(map[string]interface{}{
+			"status": ProjectExportFailed,
+			"error":  err.Error(),
+		})
+	}
+
+	tmp, err := os.CreateTemp("", "apex-project-export-*.zip")
+	if err != nil {
+		fail(fmt.Errorf("create temp file: %w", err))
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	manifest, err := s.writeProjectExport(ctx, export.UserID, tmp)
+	if err != nil {
+		fail(err)
+		return
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		fail(err)
+		return
+	}
+	key := fmt.Sprintf("exports/%d/projects-%d.zip", export.UserID, export.ID)
+	if err := s.store.Put(ctx, key, tmp, size, "application/zip"); err != nil {
+		fail(fmt.Errorf("upload archive: %w", err))
+		return
+	}
+
+	files := 0
+	for _, p := range manifest.Projects {
+		files += p.Files
+	}
+	now := s.now()
+	expiresAt := now.Add(ProjectExportTTL)
+	export.Status = ProjectExportCompleted
+	export.Projects = len(manifest.Projects)
+	export.Files = files
+	export.SizeBytes = size
+	export.ArtifactKey = key
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+	if err := s.db.WithContext(ctx).Select("status", "projects", "files", "size_bytes", "artifact_key", "completed_at", "expires_at").
+		Updates(export).Error; err != nil {
+		fail(fmt.Errorf("record export: %w", err))
+		return
+	}
+
+	if s.mailer == nil {
+		return
+	}
+	user, err := s.loadUser(ctx, export.UserID)
+	if err != nil {
+		log.Printf("privacy: project export %d ready but user lookup failed: %v", export.ID, err)
+		return
+	}
+	if err := s.mailer.SendProjectExportReady(user.Email, user.Username, export.Projects, expiresAt); err != nil {
+		log.Printf("privacy: project export %d ready but notification failed: %v", export.ID, err)
+	}
+}
+
+// writeProjectExport writes manifest.json and, per project,
+// projects/<id>/project.json, env.json and its files.
+func (s *Service) writeProjectExport(ctx context.Context, userID uint, w io.Writer) (*projectExportManifest, error) {
+	db := s.db.WithContext(ctx)
+	zw := zip.NewWriter(w)
+
+	var projects []models.Project
+	if err := db.Where("owner_id = ?", userID).Order("id").Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("privacy: load projects failed: %w", err)
+	}
+
+	manifest := &projectExportManifest{UserID: userID, ExportedAt: s.now(), Projects: []projectManifestRecord{}}
+	for _, project := range projects {
+		dir := fmt.Sprintf("projects/%d", project.ID)
+		env, err := s.projectEnvNames(ctx, project)
+		if err != nil {
+			return nil, err
+		}
+		var files int64
+		if err := db.Model(&models.File{}).Where("project_id = ? AND type <> ?", project.ID, "directory").Count(&files).Error; err != nil {
+			return nil, fmt.Errorf("privacy: count files for project %d failed: %w", project.ID, err)
+		}
+
+		project.Environment = nil
+		if err := s.exportProject(ctx, zw, project); err != nil {
+			return nil, err
+		}
+		if err := writeJSON(zw, dir+"/env.json", env); err != nil {
+			return nil, err
+		}
+		manifest.Projects = append(manifest.Projects, projectManifestRecord{
+			ID:       project.ID,
+			Name:     project.Name,
+			Path:     dir,
+			Files:    int(files),
+			Archived: project.IsArchived && project.ArchiveKey != "",
+		})
+	}
+
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("privacy: finalize project export failed: %w", err)
+	}
+	return manifest, nil
+}
+
+// projectEnvNames collects variable names from the project's environment,
+// its secrets, its pipeline environments and its deployments.
+func (s *Service) projectEnvNames(ctx context.Context, project models.Project) (*projectEnvNames, error) {
+	db := s.db.WithContext(ctx)
+	names := &projectEnvNames{Project: make([]string, 0, len(project.Environment))}
+	for key := range project.Environment {
+		names.Project = append(names.Project, key)
+	}
+	sort.Strings(names.Project)
+
+	if db.Migrator().HasTable("secrets") {
+		if err := db.Table("secrets").Where("project_id = ?", project.ID).Order("name").Pluck("name", &names.Secrets).Error; err != nil {
+			return nil, fmt.Errorf("privacy: load secret names for project %d failed: %w", project.ID, err)
+		}
+	}
+	if db.Migrator().HasTable("project_environments") {
+		var stages []struct {
+			Name       string
+			EnvVarKeys []string `gorm:"serializer:json"`
+		}
+		if err := db.Table("project_environments").Select("name", "env_var_keys").Where("project_id = ?", project.ID).Find(&stages).Error; err != nil {
+			return nil, fmt.Errorf("privacy: load environments for project %d failed: %w", project.ID, err)
+		}
+		for _, stage := range stages {
+			if names.Environments == nil {
+				names.Environments = make(map[string][]string)
+			}
+			names.Environments[stage.Name] = stage.EnvVarKeys
+		}
+	}
+	if db.Migrator().HasTable("deployment_env_vars") {
+		if err := db.Table("deployment_env_vars").Where("project_id = ? AND deleted_at IS NULL", project.ID).
+			Distinct().Order("key").Pluck("key", &names.Deployment).Error; err != nil {
+			return nil, fmt.Errorf("privacy: load deployment variables for project %d failed: %w", project.ID, err)
+		}
+	}
+	return names, nil
+}
+
+// expireProjectExports removes archives past their expiry and fails exports
+// interrupted by a restart.
+func (s *Service) expireProjectExports(ctx context.Context, now time.Time) {
+	db := s.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&ProjectExport{}) {
+		return
+	}
+	db.Model(&ProjectExport{}).
+		Where("status IN ? AND updated_at < ?", []string{ProjectExportQueued, ProjectExportRunning}, now.Add(-projectExportStaleAfter)).
+		Updates(map[string]interface{}{"status": ProjectExportFailed, "error": "export was interrupted"})
+
+	var expired []ProjectExport
+	if err := db.Where("status = ? AND expires_at < ?", ProjectExportCompleted, now).Find(&expired).Error; err != nil {
+		log.Printf("privacy: load expired project exports failed: %v", err)
+		return
+	}
+	for _, export := range expired {
+		if s.store != nil && export.ArtifactKey != "" {
+			if err := s.store.Delete(ctx, export.ArtifactKey); err != nil {
+				log.Printf("privacy: delete project export %d failed: %v", export.ID, err)
+				continue
+			}
+		}
+		db.Model(&export).Update("status", ProjectExportExpired)
+	}
+}
@@ -0,0 +1,138 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+)
+
+func TestProjectExportPackagesProjectsAndNotifies(t *testing.T) {
+	svc, db, user, mailer := setupPrivacyTest(t)
+	store, err := storage.NewLocalProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("local store: %v", err)
+	}
+	svc.SetStorageProvider(store)
+	svc.launch = func(fn func()) { fn() }
+	if err := db.AutoMigrate(&ProjectExport{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+
+	project := seedUserData(t, db, user.ID)
+	db.Model(&project).Update("environment", `{"API_TOKEN":"s3cr3t-value","PORT":"8080"}`)
+	projectID := project.ID
+	db.Create(&models.Project{Name: "other-user", OwnerID: user.ID + 100})
+	db.Exec("UPDATE secrets SET project_id = ?", projectID)
+
+	export, err := svc.RequestProjectExport(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RequestProjectExport: %v", err)
+	}
+	var stored ProjectExport
+	db.First(&stored, export.ID)
+	if stored.Status != ProjectExportCompleted || stored.Projects != 1 || stored.Files != 1 || stored.ExpiresAt == nil {
+		t.Fatalf("unexpected export %+v", stored)
+	}
+	if mailer.exportsReady != 1 || mailer.exportedCount != 1 {
+		t.Fatalf("expected one ready notification, got %d", mailer.exportsReady)
+	}
+
+	_, reader, size, err := svc.OpenProjectExport(ctx, user.ID, export.ID)
+	if err != nil {
+		t.Fatalf("OpenProjectExport: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	zr, err := zip.NewReader(bytes.NewReader(data), size)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	entries := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		entries[f.Name] = string(content)
+	}
+	dir := fmt.Sprintf("projects/%d", projectID)
+	if entries[dir+"/files/main.go"] != "package main" {
+		t.Fatalf("project file missing; have %v", entries)
+	}
+	var env projectEnvNames
+	if err := json.Unmarshal([]byte(entries[dir+"/env.json"]), &env); err != nil {
+		t.Fatalf("decode env.json: %v", err)
+	}
+	if strings.Join(env.Project, ",") != "API_TOKEN,PORT" || strings.Join(env.Secrets, ",") != "DB_URL" {
+		t.Fatalf("unexpected env names %+v", env)
+	}
+	for name, content := range entries {
+		if strings.Contains(content, "s3cr3t-value") {
+			t.Fatalf("%s leaked an environment value", name)
+		}
+	}
+	if !strings.Contains(entries["manifest.json"], `"name": "app"`) || strings.Contains(entries["manifest.json"], "other-user") {
+		t.Fatalf("unexpected manifest %s", entries["manifest.json"])
+	}
+
+	if _, _, _, err := svc.OpenProjectExport(ctx, user.ID+1, export.ID); !errors.Is(err, ErrExportNotFound) {
+		t.Fatalf("other user download err = %v", err)
+	}
+}
+
+func TestProjectExportRateLimitAndExpiry(t *testing.T) {
+	svc, db, user, _ := setupPrivacyTest(t)
+	if _, err := svc.RequestProjectExport(context.Background(), user.ID); !errors.Is(err, ErrExportUnavailable) {
+		t.Fatalf("without a store err = %v", err)
+	}
+	store, err := storage.NewLocalProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("local store: %v", err)
+	}
+	svc.SetStorageProvider(store)
+	svc.launch = func(fn func()) { fn() }
+	if err := db.AutoMigrate(&ProjectExport{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	seedUserData(t, db, user.ID)
+
+	first, err := svc.RequestProjectExport(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RequestProjectExport: %v", err)
+	}
+	again, err := svc.RequestProjectExport(ctx, user.ID)
+	if !errors.Is(err, ErrExportRateLimited) || again.ID != first.ID {
+		t.Fatalf("second export = %+v, %v; want rate limited", again, err)
+	}
+
+	base := time.Now().UTC()
+	svc.now = func() time.Time { return base.Add(ProjectExportInterval + time.Minute) }
+	if _, err := svc.RequestProjectExport(ctx, user.ID); err != nil {
+		t.Fatalf("export after a day: %v", err)
+	}
+
+	var stored ProjectExport
+	db.First(&stored, first.ID)
+	svc.expireProjectExports(ctx, stored.ExpiresAt.Add(time.Minute))
+	db.First(&stored, first.ID)
+	if stored.Status != ProjectExportExpired {
+		t.Fatalf("status = %s, want expired", stored.Status)
+	}
+	if exists, _ := store.Exists(ctx, stored.ArtifactKey); exists {
+		t.Fatal("expired archive was not deleted")
+	}
+	if _, _, _, err := svc.OpenProjectExport(ctx, user.ID, first.ID); !errors.Is(err, ErrExportNotReady) {
+		t.Fatalf("expired download err = %v", err)
+	}
+}
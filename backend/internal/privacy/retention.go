@@ -0,0 +1,105 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+)
+
+// DefaultRetentionInterval is how often the retention job runs.
+const DefaultRetentionInterval = 6 * time.Hour
+
+// RetentionResult summarizes one retention pass.
+type RetentionResult struct {
+	AuditLogsPurged  int64 `json:"audit_logs_purged"`
+	AIRequestsPurged int64 `json:"ai_requests_purged"`
+	OrgsSkipped      int   `json:"orgs_skipped"`
+}
+
+// RunRetention enforces each organization's retention windows: audit logs
+// older than AuditLogRetentionDays (and past any per-row RetainUntil) are
+// purged, as is members' AI request history older than DataRetentionDays.
+// Organizations under legal hold are skipped, and a member of any held
+// organization keeps their AI history.
+func (s *Service) RunRetention(ctx context.Context, now time.Time) (*RetentionResult, error) {
+	db := s.db.WithContext(ctx)
+	result := &RetentionResult{}
+	if !db.Migrator().HasTable(&enterprise.Organization{}) {
+		return result, nil
+	}
+
+	var orgs []enterprise.Organization
+	if err := db.Select("id", "legal_hold", "audit_log_retention_days", "data_retention_days").Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("privacy: load organizations failed: %w", err)
+	}
+
+	heldMembers := db.Table("organization_members").Select("user_id").
+		Where("deleted_at IS NULL AND organization_id IN (?)",
+			db.Model(&enterprise.Organization{}).Select("id").Where("legal_hold = ?", true))
+
+	for _, org := range orgs {
+		if org.LegalHold {
+			result.OrgsSkipped++
+			continue
+		}
+
+		if org.AuditLogRetentionDays > 0 {
+			cutoff := now.AddDate(0, 0, -org.AuditLogRetentionDays)
+			res := db.Where("organization_id = ? AND created_at < ?", org.ID, cutoff).
+				Where("retain_until IS NULL OR retain_until < ?", now).
+				Delete(&enterprise.AuditLog{})
+			if res.Error != nil {
+				log.Printf("privacy: audit log retention for org %d failed: %v", org.ID, res.Error)
+			} else {
+				result.AuditLogsPurged += res.RowsAffected
+			}
+		}
+
+		if org.DataRetentionDays > 0 {
+			cutoff := now.AddDate(0, 0, -org.DataRetentionDays)
+			members := db.Table("organization_members").Select("user_id").
+				Where("organization_id = ? AND deleted_at IS NULL", org.ID)
+			res := db.Unscoped().
+				Where("created_at < ? AND user_id IN (?) AND user_id NOT IN (?)", cutoff, members, heldMembers).
+				Delete(&models.AIRequest{})
+			if res.Error != nil {
+				log.Printf("privacy: data retention for org %d failed: %v", org.ID, res.Error)
+			} else {
+				result.AIRequestsPurged += res.RowsAffected
+			}
+		}
+	}
+	return result, nil
+}
+
+// Start launches the periodic retention job, which also removes expired
+// project exports.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.expireProjectExports(ctx, s.now())
+				result, err := s.RunRetention(ctx, s.now())
+				if err != nil {
+					log.Printf("privacy: retention run failed: %v", err)
+					continue
+				}
+				if result.AuditLogsPurged > 0 || result.AIRequestsPurged > 0 {
+					log.Printf("privacy: retention purged %d audit logs and %d AI requests", result.AuditLogsPurged, result.AIRequestsPurged)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,638 @@
+// Package privacy implements the data-subject workflows required by GDPR and
+// CCPA: a full export of a user's data as a zip archive, a verified account
+// deletion that cascades across every user-keyed table, and the retention job
+// that enforces organization retention windows. Organizations under legal
+// hold are exempt from purges and keep their audit trail (pseudonymized)
+// when a member deletes their account.
+package privacy
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// DeletionCodeTTL is how long an emailed deletion code stays valid.
+	DeletionCodeTTL = 30 * time.Minute
+	// MaxDeletionAttempts is the number of wrong codes tolerated before the
+	// request is cancelled and the user has to start over.
+	MaxDeletionAttempts = 5
+)
+
+// Deletion request statuses.
+const (
+	DeletionPending   = "pending"
+	DeletionCompleted = "completed"
+	DeletionCancelled = "cancelled"
+)
+
+var (
+	// ErrUserNotFound is returned when the account does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidPassword is returned when the re-entered password is wrong.
+	ErrInvalidPassword = errors.New("password is incorrect")
+	// ErrNoPendingDeletion is returned when confirming without a request.
+	ErrNoPendingDeletion = errors.New("no pending deletion request")
+	// ErrDeletionExpired is returned when the code has expired.
+	ErrDeletionExpired = errors.New("deletion code has expired")
+	// ErrInvalidCode is returned when the code does not match.
+	ErrInvalidCode = errors.New("deletion code is incorrect")
+	// ErrTooManyAttempts is returned once MaxDeletionAttempts is exceeded.
+	ErrTooManyAttempts = errors.New("too many incorrect codes; request a new one")
+	// ErrReauthUnavailable is returned when an account without a password
+	// asks to be deleted but no code can be emailed to re-authenticate it.
+	ErrReauthUnavailable = errors.New("email delivery is not configured; accounts without a password cannot be re-authenticated")
+)
+
+// DeletionRequest tracks a verified account deletion from request to
+// completion. The confirmation code is stored only as a bcrypt hash.
+type DeletionRequest struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint             `json:"user_id" gorm:"not null;index"`
+	Status      string           `json:"status" gorm:"size:20;not null;index"`
+	CodeHash    string           `json:"-" gorm:"not null"`
+	Attempts    int              `json:"-" gorm:"default:0"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	HeldOrgIDs  []uint           `json:"legal_hold_organization_ids,omitempty" gorm:"serializer:json"`
+	Report      map[string]int64 `json:"report,omitempty" gorm:"serializer:json"`
+}
+
+// TableName keeps the table name explicit.
+func (DeletionRequest) TableName() string { return "account_deletion_requests" }
+
+// DeletionReport summarizes what an account deletion removed or retained.
+type DeletionReport struct {
+	UserID                uint             `json:"user_id"`
+	DeletedRows           map[string]int64 `json:"deleted_rows"`
+	AuditLogsDeleted      int64            `json:"audit_logs_deleted"`
+	AuditLogsRetained     int64            `json:"audit_logs_retained"`
+	LegalHoldOrgIDs       []uint           `json:"legal_hold_organization_ids,omitempty"`
+	StripeCustomerDeleted bool             `json:"stripe_customer_deleted"`
+	CompletedAt           time.Time        `json:"completed_at"`
+}
+
+// CustomerDeleter removes the billing customer. *payments.StripeService
+// satisfies it.
+type CustomerDeleter interface {
+	IsConfigured() bool
+	DeleteCustomer(ctx context.Context, customerID string) error
+}
+
+// Mailer delivers deletion confirmation codes and project export notices.
+// *email.Service satisfies it.
+type Mailer interface {
+	SendAccountDeletionCode(to, username, code string) error
+	SendProjectExportReady(to, username string, projects int, expiresAt time.Time) error
+}
+
+// Service runs exports, account deletions, and retention purges.
+type Service struct {
+	db     *gorm.DB
+	stripe CustomerDeleter
+	mailer Mailer
+	store  storage.Provider
+	now    func() time.Time
+	launch func(func()) // runs project exports in the background
+
+	exportMu sync.Mutex
+}
+
+// NewService creates a new privacy Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:     db,
+		now:    func() time.Time { return time.Now().UTC() },
+		launch: func(fn func()) { go fn() },
+	}
+}
+
+// SetCustomerDeleter wires billing so deletion also removes the Stripe customer.
+func (s *Service) SetCustomerDeleter(d CustomerDeleter) { s.stripe = d }
+
+// SetMailer wires delivery of deletion confirmation codes.
+func (s *Service) SetMailer(m Mailer) { s.mailer = m }
+
+// SetStorageProvider wires the artifact store so exports include cold-storage
+// project archives and deletion removes them.
+func (s *Service) SetStorageProvider(p storage.Provider) { s.store = p }
+
+// RequestDeletion re-verifies the user's password, cancels any earlier
+// pending request, and emails a one-time confirmation code. Accounts without
+// a password (SSO and SCIM users) are re-authenticated by the emailed code
+// alone, so the password is ignored for them.
+func (s *Service) RequestDeletion(ctx context.Context, userID uint, password string) (*DeletionRequest, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" {
+		if s.mailer == nil {
+			return nil, ErrReauthUnavailable
+		}
+	} else if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return nil, fmt.Errorf("privacy: generate code failed: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("privacy: hash code failed: %w", err)
+	}
+	heldOrgIDs, err := s.heldOrgIDs(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := DeletionRequest{
+		UserID:     userID,
+		Status:     DeletionPending,
+		CodeHash:   string(hash),
+		ExpiresAt:  s.now().Add(DeletionCodeTTL),
+		HeldOrgIDs: heldOrgIDs,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&DeletionRequest{}).
+			Where("user_id = ? AND status = ?", userID, DeletionPending).
+			Update("status", DeletionCancelled).Error; err != nil {
+			return err
+		}
+		return tx.Create(&req).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("privacy: create deletion request failed: %w", err)
+	}
+
+	if s.mailer != nil {
+		if err := s.mailer.SendAccountDeletionCode(user.Email, user.Username, code); err != nil {
+			return nil, fmt.Errorf("privacy: send deletion code failed: %w", err)
+		}
+	}
+	return &req, nil
+}
+
+// CancelDeletion withdraws the user's pending deletion request.
+func (s *Service) CancelDeletion(ctx context.Context, userID uint) error {
+	res := s.db.WithContext(ctx).Model(&DeletionRequest{}).
+		Where("user_id = ? AND status = ?", userID, DeletionPending).
+		Update("status", DeletionCancelled)
+	if res.Error != nil {
+		return fmt.Errorf("privacy: cancel deletion failed: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNoPendingDeletion
+	}
+	return nil
+}
+
+// ConfirmDeletion checks the emailed code and, when it matches, permanently
+// deletes the account.
+func (s *Service) ConfirmDeletion(ctx context.Context, userID uint, code string) (*DeletionReport, error) {
+	var req DeletionRequest
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND status = ?", userID, DeletionPending).
+		Order("id DESC").First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoPendingDeletion
+	}
+	if err != nil {
+		return nil, fmt.Errorf("privacy: load deletion request failed: %w", err)
+	}
+	if s.now().After(req.ExpiresAt) {
+		s.db.WithContext(ctx).Model(&req).Update("status", DeletionCancelled)
+		return nil, ErrDeletionExpired
+	}
+	if bcrypt.CompareHashAndPassword([]byte(req.CodeHash), []byte(strings.TrimSpace(code))) != nil {
+		attempts := req.Attempts + 1
+		updates := map[string]interface{}{"attempts": attempts}
+		if attempts >= MaxDeletionAttempts {
+			updates["status"] = DeletionCancelled
+		}
+		s.db.WithContext(ctx).Model(&req).Updates(updates)
+		if attempts >= MaxDeletionAttempts {
+			return nil, ErrTooManyAttempts
+		}
+		return nil, ErrInvalidCode
+	}
+
+	report, err := s.deleteAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	req.Status = DeletionCompleted
+	req.CompletedAt = &report.CompletedAt
+	req.Report = report.DeletedRows
+	s.db.WithContext(ctx).Select("status", "completed_at", "report").Updates(&req)
+	return report, nil
+}
+
+// cascadeStep hard-deletes rows from one table. Tables that do not exist in
+// this deployment (optional subsystems) are skipped.
+type cascadeStep struct {
+	table string
+	where string
+}
+
+// projectScope matches rows belonging to projects the user owns. Organization
+// projects belong to the organization whoever is recorded as owner_id, so
+// they are never deleted with an account.
+const projectScope = "project_id IN (SELECT id FROM projects WHERE owner_id = ? AND organization_id IS NULL)"
+
+// ownedProjects is the set of projects the user owns, for projectCascade.
+const ownedProjects = "(SELECT id FROM projects WHERE owner_id = ? AND organization_id IS NULL)"
+
+// projectCascade lists the tables holding a project's rows, children first.
+// The rows go with the project whoever created them. %[1]s stands for the
+// set of project IDs being deleted.
+var projectCascade = []cascadeStep{
+	{"deployment_logs", "deployment_id IN (SELECT id FROM deployments WHERE project_id IN %[1]s)"},
+	{"deployments", "project_id IN %[1]s"},
+	{"deployment_env_vars", "project_id IN %[1]s"},
+	{"deployment_history", "project_id IN %[1]s"},
+	{"native_deployments", "project_id IN %[1]s"},
+	{"worker_processes", "project_id IN %[1]s"},
+	{"subdomains", "project_id IN %[1]s"},
+	{"custom_domains", "project_id IN %[1]s"},
+	{"csp_violations", "project_id IN %[1]s"},
+	{"error_events", "group_id IN (SELECT id FROM error_groups WHERE project_id IN %[1]s)"},
+	{"error_groups", "project_id IN %[1]s"},
+	{"file_versions", "project_id IN %[1]s"},
+	{"file_symbols", "project_id IN %[1]s"},
+	{"file_symbol_states", "project_id IN %[1]s"},
+	{"code_comments", "project_id IN %[1]s"},
+	{"collab_rooms", "project_id IN %[1]s"},
+	{"execution_artifacts", "project_id IN %[1]s"},
+	{"executions", "project_id IN %[1]s"},
+	{"project_assets", "project_id IN %[1]s"},
+	{"environment_releases", "project_id IN %[1]s"},
+	{"build_artifacts", "project_id IN %[1]s"},
+	{"git_issue_builds", "project_id IN %[1]s"},
+	{"git_synced_files", "project_id IN %[1]s"},
+	{"git_sync_conflicts", "project_id IN %[1]s"},
+	{"repositories", "project_id IN %[1]s"},
+	{"dependency_update_runs", "project_id IN %[1]s"},
+	{"dependency_update_policies", "project_id IN %[1]s"},
+	{"scheduled_task_runs", "project_id IN %[1]s"},
+	{"scheduled_tasks", "project_id IN %[1]s"},
+	{"replace_operations", "project_id IN %[1]s"},
+	{"project_restore_points", "project_id IN %[1]s"},
+	{"project_environments", "project_id IN %[1]s"},
+	{"project_service_token_usage", "project_id IN %[1]s"},
+	{"project_service_tokens", "project_id IN %[1]s"},
+	{"log_drains", "project_id IN %[1]s"},
+	{"external_mcp_servers", "project_id IN %[1]s"},
+	{"project_stars", "project_id IN %[1]s"},
+	{"project_forks", "original_id IN %[1]s OR forked_id IN %[1]s"},
+	{"project_comments", "project_id IN %[1]s"},
+	{"project_views", "project_id IN %[1]s"},
+	{"featured_projects", "project_id IN %[1]s"},
+	{"project_category_assignments", "project_id IN %[1]s"},
+	{"project_stats", "project_id IN %[1]s"},
+	{"project_activities", "project_id IN %[1]s"},
+	{"project_permissions", "project_id IN %[1]s"},
+	{"project_transfers", "project_id IN %[1]s"},
+	{"project_storage_usage", "project_id IN %[1]s"},
+	{"ai_requests", "project_id IN %[1]s"},
+	{"secrets", "project_id IN %[1]s"},
+	{"files", "project_id IN %[1]s"},
+}
+
+// detachStep clears a reference column instead of deleting the row.
+type detachStep struct {
+	table  string
+	column string
+	where  string
+}
+
+// projectDetach lists references to a project kept in records that outlive
+// it, such as other users' sessions and usage history. They are cleared
+// before the project is deleted. %[1]s is as in projectCascade.
+var projectDetach = []detachStep{
+	{"sessions", "current_project_id", "current_project_id IN %[1]s"},
+	{"ai_usage_logs", "project_id", "project_id IN %[1]s"},
+	{"spend_events", "project_id", "project_id IN %[1]s"},
+}
+
+// userDetach lists other users' rows that reference rows of the user.
+var userDetach = []detachStep{
+	{"project_comments", "parent_id", "parent_id IN (SELECT id FROM project_comments WHERE user_id = ?)"},
+}
+
+// userCascade lists the tables keyed by the user, children first.
+// credit_ledger_entries is deliberately absent: billing records are kept for
+// tax purposes and point only at the scrubbed user row.
+var userCascade = []cascadeStep{
+	{"deployment_logs", "deployment_id IN (SELECT id FROM deployments WHERE user_id = ?)"},
+	{"deployments", "user_id = ?"},
+	{"code_comments", "author_id = ?"},
+	{"cursor_positions", "user_id = ?"},
+	{"executions", "user_id = ?"},
+	{"project_assets", "user_id = ?"},
+	{"git_issue_builds", "user_id = ?"},
+	{"dependency_update_runs", "user_id = ?"},
+	{"dependency_update_policies", "user_id = ?"},
+	{"external_mcp_servers", "user_id = ?"},
+	{"project_stars", "user_id = ?"},
+	{"project_forks", "user_id = ?"},
+	{"project_comments", "user_id = ?"},
+	{"project_views", "user_id = ?"},
+	{"user_follows", "follower_id = ? OR following_id = ?"},
+	{"user_stats", "user_id = ?"},
+	{"completed_builds", "user_id = ?"},
+	{"build_outcomes", "user_id = ?"},
+	{"support_bundles", "user_id = ?"},
+	{"project_exports", "user_id = ?"},
+	{"ai_requests", "user_id = ?"},
+	{"ai_usage_logs", "user_id = ?"},
+	{"ai_usage_daily", "user_id = ?"},
+	{"completion_events", "user_id = ?"},
+	{"completion_settings", "user_id = ?"},
+	{"spend_events", "user_id = ?"},
+	{"spend_anomalies", "user_id = ?"},
+	{"budget_reservations", "user_id = ?"},
+	{"budget_caps", "user_id = ?"},
+	{"secret_audit_logs", "user_id = ?"},
+	{"secrets", "user_id = ?"},
+	{"user_api_keys", "user_id = ?"},
+	{"chat_messages", "user_id = ?"},
+	{"user_collab_rooms", "user_id = ?"},
+	{"notifications", "user_id = ?"},
+	{"onboarding_step_completions", "user_id = ?"},
+	{"onboarding_states", "user_id = ?"},
+	{"referral_clicks", "referrer_id = ?"},
+	{"referrals", "referrer_id = ? OR referred_user_id = ?"},
+	{"referral_codes", "user_id = ?"},
+	{"abuse_signals", "user_id = ?"},
+	{"abuse_cases", "user_id = ?"},
+	{"management_tokens", "user_id = ?"},
+	{"sessions", "user_id = ?"},
+	{"refresh_tokens", "user_id = ?"},
+	{"user_identities", "user_id = ?"},
+	{"organization_members", "user_id = ?"},
+}
+
+// deletionCascade lists every table holding personal data in the order it
+// is deleted: rows of the user's projects, then rows keyed by the user, and
+// the projects themselves last since most of the others reference them.
+var deletionCascade = buildDeletionCascade()
+
+func buildDeletionCascade() []cascadeStep {
+	steps := make([]cascadeStep, 0, len(projectCascade)+len(userCascade)+1)
+	for _, step := range projectCascade {
+		steps = append(steps, cascadeStep{step.table, strings.ReplaceAll(step.where, "%[1]s", ownedProjects)})
+	}
+	steps = append(steps, userCascade...)
+	return append(steps, cascadeStep{"projects", "owner_id = ? AND organization_id IS NULL"})
+}
+
+// deleteSteps runs steps against tx, binding arg to every placeholder, and
+// adds the rows removed to deleted. Tables missing from this deployment are
+// skipped.
+func deleteSteps(tx *gorm.DB, steps []cascadeStep, arg interface{}, deleted map[string]int64) error {
+	for _, step := range steps {
+		if !tx.Migrator().HasTable(step.table) {
+			continue
+		}
+		args := make([]interface{}, strings.Count(step.where, "?"))
+		for i := range args {
+			args[i] = arg
+		}
+		res := tx.Exec("DELETE FROM "+step.table+" WHERE "+step.where, args...)
+		if res.Error != nil {
+			return fmt.Errorf("privacy: delete from %s failed: %w", step.table, res.Error)
+		}
+		deleted[step.table] += res.RowsAffected
+	}
+	return nil
+}
+
+// detachSteps clears the references matched by steps, binding arg to
+// every placeholder. %[1]s in a step is replaced by set.
+func detachSteps(tx *gorm.DB, steps []detachStep, set string, arg interface{}) error {
+	for _, step := range steps {
+		if !tx.Migrator().HasTable(step.table) {
+			continue
+		}
+		where := strings.ReplaceAll(step.where, "%[1]s", set)
+		args := make([]interface{}, strings.Count(where, "?"))
+		for i := range args {
+			args[i] = arg
+		}
+		if err := tx.Exec("UPDATE "+step.table+" SET "+step.column+" = NULL WHERE "+where, args...).Error; err != nil {
+			return fmt.Errorf("privacy: detach %s failed: %w", step.table, err)
+		}
+	}
+	return nil
+}
+
+// PurgeProjects hard-deletes projects and every row that belongs to them in
+// one transaction, returning the rows removed per table. References from
+// records that outlive the projects are cleared instead.
+func PurgeProjects(tx *gorm.DB, projectIDs []uint) (map[string]int64, error) {
+	deleted := make(map[string]int64)
+	if len(projectIDs) == 0 {
+		return deleted, nil
+	}
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := detachSteps(tx, projectDetach, "?", projectIDs); err != nil {
+			return err
+		}
+		steps := make([]cascadeStep, 0, len(projectCascade)+1)
+		for _, step := range projectCascade {
+			steps = append(steps, cascadeStep{step.table, strings.ReplaceAll(step.where, "%[1]s", "?")})
+		}
+		steps = append(steps, cascadeStep{"projects", "id IN ?"})
+		return deleteSteps(tx, steps, projectIDs, deleted)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+func (s *Service) deleteAccount(ctx context.Context, userID uint) (*DeletionReport, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeletionReport{UserID: userID, DeletedRows: make(map[string]int64)}
+
+	// Stripe goes first: if it fails the account stays intact and the user
+	// can retry, rather than leaving a billable customer with no account.
+	if user.StripeCustomerID != "" && s.stripe != nil && s.stripe.IsConfigured() {
+		if err := s.stripe.DeleteCustomer(ctx, user.StripeCustomerID); err != nil {
+			return nil, fmt.Errorf("privacy: delete billing customer failed: %w", err)
+		}
+		report.StripeCustomerDeleted = true
+	}
+
+	var archiveKeys []string
+	var assetPaths []string
+	s.db.WithContext(ctx).Unscoped().Model(&models.Project{}).
+		Where("owner_id = ? AND organization_id IS NULL AND archive_key <> ''", userID).Pluck("archive_key", &archiveKeys)
+	if s.db.Migrator().HasTable(&ProjectExport{}) {
+		var exportKeys []string
+		s.db.WithContext(ctx).Model(&ProjectExport{}).
+			Where("user_id = ? AND status = ? AND artifact_key <> ''", userID, ProjectExportCompleted).Pluck("artifact_key", &exportKeys)
+		archiveKeys = append(archiveKeys, exportKeys...)
+	}
+	if s.db.Migrator().HasTable(&models.ProjectAsset{}) {
+		s.db.WithContext(ctx).Unscoped().Model(&models.ProjectAsset{}).
+			Where("user_id = ? OR "+projectScope, userID, userID).Pluck("storage_path", &assetPaths)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		heldOrgIDs, err := s.heldOrgIDs(tx, userID)
+		if err != nil {
+			return err
+		}
+		report.LegalHoldOrgIDs = heldOrgIDs
+
+		if err := s.scrubAuditLogs(tx, userID, heldOrgIDs, report); err != nil {
+			return err
+		}
+
+		if err := detachSteps(tx, projectDetach, ownedProjects, userID); err != nil {
+			return err
+		}
+		if err := detachSteps(tx, userDetach, "", userID); err != nil {
+			return err
+		}
+		if err := deleteSteps(tx, deletionCascade, userID, report.DeletedRows); err != nil {
+			return err
+		}
+
+		// The user row is scrubbed and soft-deleted rather than removed so
+		// retained audit logs and billing records keep a valid reference.
+		placeholder := fmt.Sprintf("deleted-user-%d", userID)
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":           placeholder,
+			"email":              placeholder + "@deleted.invalid",
+			"password_hash":      "",
+			"full_name":          "",
+			"avatar_url":         "",
+			"stripe_customer_id": "",
+			"subscription_id":    "",
+			"is_active":          false,
+			"verification_code":  "",
+		}).Error; err != nil {
+			return fmt.Errorf("privacy: scrub user failed: %w", err)
+		}
+		return tx.Delete(&models.User{}, userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Object and disk cleanup happens after commit; a failure here leaves
+	// only unreachable blobs, so it is logged rather than surfaced.
+	for _, key := range archiveKeys {
+		if s.store == nil {
+			break
+		}
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("privacy: delete archive %s for user %d failed: %v", key, userID, err)
+		}
+	}
+	for _, p := range assetPaths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			log.Printf("privacy: delete asset %s for user %d failed: %v", p, userID, err)
+		}
+	}
+
+	report.CompletedAt = s.now()
+	return report, nil
+}
+
+// scrubAuditLogs deletes the user's audit logs, except rows belonging to an
+// organization under legal hold, which are kept with identifying fields
+// stripped.
+func (s *Service) scrubAuditLogs(tx *gorm.DB, userID uint, heldOrgIDs []uint, report *DeletionReport) error {
+	if !tx.Migrator().HasTable(&enterprise.AuditLog{}) {
+		return nil
+	}
+	if len(heldOrgIDs) > 0 {
+		res := tx.Model(&enterprise.AuditLog{}).
+			Where("user_id = ? AND organization_id IN ?", userID, heldOrgIDs).
+			Updates(map[string]interface{}{
+				"username":   fmt.Sprintf("deleted-user-%d", userID),
+				"email":      "",
+				"ip_address": "",
+				"user_agent": "",
+			})
+		if res.Error != nil {
+			return fmt.Errorf("privacy: pseudonymize audit logs failed: %w", res.Error)
+		}
+		report.AuditLogsRetained = res.RowsAffected
+	}
+
+	query := tx.Where("user_id = ?", userID)
+	if len(heldOrgIDs) > 0 {
+		query = query.Where("organization_id IS NULL OR organization_id NOT IN ?", heldOrgIDs)
+	}
+	res := query.Delete(&enterprise.AuditLog{})
+	if res.Error != nil {
+		return fmt.Errorf("privacy: delete audit logs failed: %w", res.Error)
+	}
+	report.AuditLogsDeleted = res.RowsAffected
+	return nil
+}
+
+// heldOrgIDs returns the organizations the user belongs to that are under
+// legal hold.
+func (s *Service) heldOrgIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	if !db.Migrator().HasTable(&enterprise.Organization{}) || !db.Migrator().HasTable(&enterprise.OrganizationMember{}) {
+		return nil, nil
+	}
+	var ids []uint
+	err := db.Model(&enterprise.Organization{}).
+		Where("legal_hold = ?", true).
+		Where("id IN (SELECT organization_id FROM organization_members WHERE user_id = ? AND deleted_at IS NULL)", userID).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("privacy: load legal holds failed: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *Service) loadUser(ctx context.Context, userID uint) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("privacy: load user failed: %w", err)
+	}
+	return &user, nil
+}
+
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
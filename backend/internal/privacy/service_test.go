@@ -0,0 +1,352 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/community"
+	"apex-build/internal/enterprise"
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	code          string
+	exportsReady  int
+	exportedCount int
+}
+
+func (m *fakeMailer) SendAccountDeletionCode(to, username, code string) error {
+	m.code = code
+	return nil
+}
+
+func (m *fakeMailer) SendProjectExportReady(to, username string, projects int, expiresAt time.Time) error {
+	m.exportsReady++
+	m.exportedCount = projects
+	return nil
+}
+
+type fakeCustomerDeleter struct{ deleted []string }
+
+func (f *fakeCustomerDeleter) IsConfigured() bool { return true }
+
+func (f *fakeCustomerDeleter) DeleteCustomer(ctx context.Context, customerID string) error {
+	f.deleted = append(f.deleted, customerID)
+	return nil
+}
+
+func setupPrivacyTest(t *testing.T) (*Service, *gorm.DB, models.User, *fakeMailer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// One connection, so PRAGMA foreign_keys applies to every query
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+	if err := db.AutoMigrate(
+		&models.User{}, &models.Project{}, &models.File{}, &models.AIRequest{},
+		&models.Session{}, &models.Execution{}, &models.CollabRoom{},
+		&models.CompletedBuild{}, &models.UserAPIKey{}, &secrets.Secret{},
+		&community.ProjectStar{}, &community.ProjectFork{}, &community.ProjectComment{},
+		&community.ProjectView{}, &community.UserFollow{}, &community.FeaturedProject{},
+		&enterprise.Organization{}, &enterprise.Role{}, &enterprise.OrganizationMember{}, &enterprise.AuditLog{},
+		&DeletionRequest{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	name := strings.ToLower(t.Name())
+	user := models.User{Username: name, Email: name + "@example.com", PasswordHash: string(hash), StripeCustomerID: "cus_123"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	mailer := &fakeMailer{}
+	svc := NewService(db)
+	svc.SetMailer(mailer)
+	return svc, db, user, mailer
+}
+
+func seedUserData(t *testing.T, db *gorm.DB, userID uint) models.Project {
+	t.Helper()
+	project := models.Project{Name: "app", Language: "go", OwnerID: userID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	rows := []interface{}{
+		&models.File{ProjectID: project.ID, Path: "main.go", Name: "main.go", Type: "file", Content: "package main", LastEditBy: userID},
+		&models.AIRequest{RequestID: "req-1", UserID: userID, Provider: "claude", Capability: "code_generation", Prompt: "build it"},
+		&models.CompletedBuild{BuildID: "build-1", UserID: userID, Status: "completed"},
+		&secrets.Secret{UserID: userID, Name: "DB_URL", EncryptedValue: "enc", KeyFingerprint: "fp", Salt: "salt"},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	return project
+}
+
+func TestConfirmDeletionCascadesAndHonorsLegalHold(t *testing.T) {
+	svc, db, user, mailer := setupPrivacyTest(t)
+	stripe := &fakeCustomerDeleter{}
+	svc.SetCustomerDeleter(stripe)
+	ctx := context.Background()
+	// Foreign keys are enforced so a parent deleted before its children fails
+	db.Exec("PRAGMA foreign_keys = ON")
+	var enforced int
+	db.Raw("PRAGMA foreign_keys").Scan(&enforced)
+	if enforced != 1 {
+		t.Fatal("foreign keys are not enforced")
+	}
+	project := seedUserData(t, db, user.ID)
+
+	// Another user's rows pointing at the deleted user's project and account
+	other := models.User{Username: "other", Email: "other@example.com", PasswordHash: "x"}
+	db.Create(&other)
+	otherProject := models.Project{Name: "fork", Language: "go", OwnerID: other.ID}
+	db.Create(&otherProject)
+	comment := community.ProjectComment{ProjectID: otherProject.ID, UserID: user.ID, Content: "nice"}
+	db.Create(&comment)
+	dependents := []interface{}{
+		&models.Session{UserID: user.ID, SessionID: "mine", CurrentProjectID: &project.ID},
+		&models.Session{UserID: other.ID, SessionID: "theirs", CurrentProjectID: &project.ID},
+		&models.AIRequest{RequestID: "req-other", UserID: other.ID, ProjectID: &project.ID, Provider: "claude", Capability: "code_generation"},
+		&models.Execution{ExecutionID: "exec-1", ProjectID: &project.ID, UserID: other.ID, Command: "go test", Language: "go"},
+		&models.CollabRoom{RoomID: "room-1", ProjectID: project.ID},
+		&secrets.Secret{UserID: other.ID, ProjectID: &project.ID, Name: "SHARED", EncryptedValue: "enc", KeyFingerprint: "fp", Salt: "salt"},
+		&community.ProjectStar{UserID: other.ID, ProjectID: project.ID},
+		&community.ProjectStar{UserID: user.ID, ProjectID: otherProject.ID},
+		&community.ProjectFork{OriginalID: project.ID, ForkedID: otherProject.ID, UserID: other.ID},
+		&community.ProjectComment{ProjectID: otherProject.ID, UserID: other.ID, ParentID: &comment.ID, Content: "thanks"},
+		&community.ProjectView{ProjectID: project.ID, UserID: &other.ID},
+		&community.UserFollow{FollowerID: other.ID, FollowingID: user.ID},
+		&community.FeaturedProject{ProjectID: project.ID, FeaturedBy: other.ID},
+	}
+	for _, row := range dependents {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+
+	held := enterprise.Organization{Name: "held", Slug: "held", LegalHold: true}
+	free := enterprise.Organization{Name: "free", Slug: "free"}
+	db.Create(&held)
+	db.Create(&free)
+	role := enterprise.Role{OrganizationID: &held.ID, Name: "Developer"}
+	db.Create(&role)
+	if err := db.Create(&enterprise.OrganizationMember{OrganizationID: held.ID, UserID: user.ID, RoleID: role.ID}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	db.Create(&enterprise.AuditLog{OrganizationID: &held.ID, UserID: &user.ID, Email: user.Email, IPAddress: "10.0.0.1", Action: "login"})
+	db.Create(&enterprise.AuditLog{OrganizationID: &free.ID, UserID: &user.ID, Email: user.Email, Action: "login"})
+	// A project the user created for an organization belongs to the org
+	orgProject := models.Project{Name: "team-app", Language: "go", OwnerID: user.ID, OrganizationID: &free.ID}
+	if err := db.Create(&orgProject).Error; err != nil {
+		t.Fatalf("create org project: %v", err)
+	}
+
+	if _, err := svc.RequestDeletion(ctx, user.ID, "wrong"); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("expected ErrInvalidPassword, got %v", err)
+	}
+	req, err := svc.RequestDeletion(ctx, user.ID, "correct horse")
+	if err != nil {
+		t.Fatalf("RequestDeletion: %v", err)
+	}
+	if len(req.HeldOrgIDs) != 1 || req.HeldOrgIDs[0] != held.ID || mailer.code == "" {
+		t.Fatalf("unexpected request %+v (code %q)", req, mailer.code)
+	}
+
+	report, err := svc.ConfirmDeletion(ctx, user.ID, mailer.code)
+	if err != nil {
+		t.Fatalf("ConfirmDeletion: %v", err)
+	}
+	if !report.StripeCustomerDeleted || len(stripe.deleted) != 1 || stripe.deleted[0] != "cus_123" {
+		t.Fatalf("expected Stripe customer deleted, got %+v", stripe.deleted)
+	}
+	if report.AuditLogsRetained != 1 || report.AuditLogsDeleted != 1 {
+		t.Fatalf("unexpected audit log handling: %+v", report)
+	}
+
+	for _, table := range []string{"files", "ai_requests", "executions", "collab_rooms", "completed_builds", "secrets", "organization_members",
+		"project_stars", "project_forks", "project_views", "user_follows", "featured_projects"} {
+		var count int64
+		db.Table(table).Count(&count)
+		if count != 0 {
+			t.Fatalf("expected %s emptied, %d rows left", table, count)
+		}
+	}
+	var projects, comments int64
+	db.Unscoped().Model(&models.Project{}).Count(&projects)
+	db.Model(&community.ProjectComment{}).Count(&comments)
+	if projects != 2 || comments != 1 {
+		t.Fatalf("other user's project, the org project and reply should stay: projects=%d comments=%d", projects, comments)
+	}
+	if err := db.First(&models.Project{}, orgProject.ID).Error; err != nil {
+		t.Fatalf("org project deleted with its creator: %v", err)
+	}
+	var theirs models.Session
+	if err := db.Where("session_id = ?", "theirs").First(&theirs).Error; err != nil || theirs.CurrentProjectID != nil {
+		t.Fatalf("other user's session should stay without the project: %+v %v", theirs, err)
+	}
+
+	var kept enterprise.AuditLog
+	db.Where("organization_id = ?", held.ID).First(&kept)
+	if kept.Email != "" || kept.IPAddress != "" || !strings.HasPrefix(kept.Username, "deleted-user-") {
+		t.Fatalf("retained audit log not pseudonymized: %+v", kept)
+	}
+
+	var scrubbed models.User
+	db.Unscoped().First(&scrubbed, user.ID)
+	if !scrubbed.DeletedAt.Valid || scrubbed.Email == user.Email || scrubbed.PasswordHash != "" {
+		t.Fatalf("user not scrubbed: %+v", scrubbed)
+	}
+}
+
+func TestRequestDeletionReauthenticatesPasswordlessAccountsByEmail(t *testing.T) {
+	svc, db, user, mailer := setupPrivacyTest(t)
+	ctx := context.Background()
+	db.Model(&user).Update("password_hash", "")
+
+	if _, err := svc.RequestDeletion(ctx, user.ID, ""); err != nil {
+		t.Fatalf("RequestDeletion for an SSO account: %v", err)
+	}
+	if mailer.code == "" {
+		t.Fatal("expected a code emailed to re-authenticate")
+	}
+	if _, err := svc.ConfirmDeletion(ctx, user.ID, mailer.code); err != nil {
+		t.Fatalf("ConfirmDeletion: %v", err)
+	}
+
+	svc.SetMailer(nil)
+	sso := models.User{Username: "sso", Email: "sso@example.com"}
+	db.Create(&sso)
+	if _, err := svc.RequestDeletion(ctx, sso.ID, ""); !errors.Is(err, ErrReauthUnavailable) {
+		t.Fatalf("expected ErrReauthUnavailable without a mailer, got %v", err)
+	}
+}
+
+func TestConfirmDeletionRejectsBadAndExpiredCodes(t *testing.T) {
+	svc, db, user, mailer := setupPrivacyTest(t)
+	ctx := context.Background()
+
+	if _, err := svc.ConfirmDeletion(ctx, user.ID, "000000"); !errors.Is(err, ErrNoPendingDeletion) {
+		t.Fatalf("expected ErrNoPendingDeletion, got %v", err)
+	}
+	if _, err := svc.RequestDeletion(ctx, user.ID, "correct horse"); err != nil {
+		t.Fatalf("RequestDeletion: %v", err)
+	}
+	wrong := "000000"
+	if mailer.code == wrong {
+		wrong = "111111"
+	}
+	for i := 1; i < MaxDeletionAttempts; i++ {
+		if _, err := svc.ConfirmDeletion(ctx, user.ID, wrong); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i, err)
+		}
+	}
+	if _, err := svc.ConfirmDeletion(ctx, user.ID, wrong); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if _, err := svc.ConfirmDeletion(ctx, user.ID, mailer.code); !errors.Is(err, ErrNoPendingDeletion) {
+		t.Fatalf("request should be cancelled after too many attempts, got %v", err)
+	}
+
+	if _, err := svc.RequestDeletion(ctx, user.ID, "correct horse"); err != nil {
+		t.Fatalf("RequestDeletion: %v", err)
+	}
+	svc.now = func() time.Time { return time.Now().UTC().Add(DeletionCodeTTL + time.Minute) }
+	if _, err := svc.ConfirmDeletion(ctx, user.ID, mailer.code); !errors.Is(err, ErrDeletionExpired) {
+		t.Fatalf("expected ErrDeletionExpired, got %v", err)
+	}
+
+	var live models.User
+	if err := db.First(&live, user.ID).Error; err != nil {
+		t.Fatalf("user should still exist: %v", err)
+	}
+}
+
+func TestExportIncludesProjectsHistoryAndOmitsSecretValues(t *testing.T) {
+	svc, db, user, _ := setupPrivacyTest(t)
+	project := seedUserData(t, db, user.ID)
+	db.Create(&enterprise.AuditLog{UserID: &user.ID, Action: "login"})
+
+	var buf bytes.Buffer
+	if err := svc.Export(context.Background(), user.ID, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open export: %v", err)
+	}
+	entries := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		entries[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"account.json", "ai_requests.json", "builds.json", "audit_logs.json", "secrets.json"} {
+		if _, ok := entries[name]; !ok {
+			t.Fatalf("export missing %s; have %v", name, entries)
+		}
+	}
+	if got := entries[fmt.Sprintf("projects/%d/files/main.go", project.ID)]; got != "package main" {
+		t.Fatalf("project file not exported, got %q", got)
+	}
+	if !strings.Contains(entries["ai_requests.json"], "build it") {
+		t.Fatal("AI history not exported")
+	}
+	if strings.Contains(entries["account.json"], "password") || strings.Contains(entries["secrets.json"], "enc") {
+		t.Fatal("export leaked credentials")
+	}
+}
+
+func TestRunRetentionSkipsLegalHold(t *testing.T) {
+	svc, db, user, _ := setupPrivacyTest(t)
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -120)
+
+	held := enterprise.Organization{Name: "held", Slug: "held", LegalHold: true, AuditLogRetentionDays: 30, DataRetentionDays: 30}
+	free := enterprise.Organization{Name: "free", Slug: "free", AuditLogRetentionDays: 30, DataRetentionDays: 30}
+	db.Create(&held)
+	db.Create(&free)
+	db.Create(&enterprise.AuditLog{OrganizationID: &held.ID, Action: "login", CreatedAt: old})
+	db.Create(&enterprise.AuditLog{OrganizationID: &free.ID, Action: "login", CreatedAt: old})
+	db.Create(&enterprise.AuditLog{OrganizationID: &free.ID, Action: "login", CreatedAt: now})
+
+	other := models.User{Username: "other", Email: "other@example.com", PasswordHash: "x"}
+	db.Create(&other)
+	db.Create(&enterprise.OrganizationMember{OrganizationID: free.ID, UserID: user.ID, RoleID: 1})
+	db.Create(&enterprise.OrganizationMember{OrganizationID: free.ID, UserID: other.ID, RoleID: 1})
+	db.Create(&enterprise.OrganizationMember{OrganizationID: held.ID, UserID: other.ID, RoleID: 1})
+	db.Create(&models.AIRequest{RequestID: "old-free", UserID: user.ID, Provider: "claude", Capability: "x", CreatedAt: old})
+	db.Create(&models.AIRequest{RequestID: "old-held", UserID: other.ID, Provider: "claude", Capability: "x", CreatedAt: old})
+
+	result, err := svc.RunRetention(context.Background(), now)
+	if err != nil {
+		t.Fatalf("RunRetention: %v", err)
+	}
+	if result.AuditLogsPurged != 1 || result.AIRequestsPurged != 1 || result.OrgsSkipped != 1 {
+		t.Fatalf("unexpected retention result %+v", result)
+	}
+	var count int64
+	db.Model(&models.AIRequest{}).Where("request_id = ?", "old-held").Count(&count)
+	if count != 1 {
+		t.Fatal("AI history of a legal-hold member must be kept")
+	}
+}
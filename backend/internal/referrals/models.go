@@ -0,0 +1,111 @@
+// Package referrals issues per-user invite links, attributes signups to
+// them and rewards referrers with credits when referred users convert to a
+// paid plan.
+package referrals
+
+import "time"
+
+// Referral statuses
+const (
+	// StatusPending is a signup that has not converted to a paid plan yet
+	StatusPending = "pending"
+	// StatusRewarded is a converted signup whose rewards were issued
+	StatusRewarded = "rewarded"
+	// StatusCapped is a converted signup past the referrer's monthly reward cap
+	StatusCapped = "capped"
+	// StatusFlagged is a signup that looks like the referrer's own account;
+	// it is tracked but never rewarded
+	StatusFlagged = "flagged"
+)
+
+// Abuse flags recorded on flagged referrals
+const (
+	FlagSameIP       = "same_ip"
+	FlagSameDevice   = "same_device"
+	FlagRepeatIP     = "repeat_ip"
+	FlagRepeatDevice = "repeat_device"
+)
+
+// Code is a user's referral link code
+type Code struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex;not null"`
+	Code      string    `json:"code" gorm:"uniqueIndex;size:16;not null"`
+}
+
+// TableName specifies the table name for Code
+func (Code) TableName() string {
+	return "referral_codes"
+}
+
+// Click is one visit to a referral link. IP addresses and device IDs are
+// stored as hashes.
+type Click struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	ReferrerID uint      `json:"referrer_id" gorm:"not null;index"`
+	IPHash     string    `json:"-" gorm:"size:64;index"`
+	DeviceHash string    `json:"-" gorm:"size:64"`
+	// IsUnique is false for repeat visits from the same IP within a day
+	IsUnique bool `json:"unique"`
+}
+
+// TableName specifies the table name for Click
+func (Click) TableName() string {
+	return "referral_clicks"
+}
+
+// Referral attributes a signup to the user whose link brought them in
+type Referral struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ReferrerID       uint   `json:"referrer_id" gorm:"not null;index"`
+	ReferredUserID   uint   `json:"referred_user_id" gorm:"uniqueIndex;not null"`
+	Status           string `json:"status" gorm:"size:16;not null;index"`
+	FlagReason       string `json:"flag_reason,omitempty" gorm:"size:32"`
+	SignupIPHash     string `json:"-" gorm:"size:64;index"`
+	SignupDeviceHash string `json:"-" gorm:"size:64;index"`
+
+	ConvertedAt       *time.Time `json:"converted_at,omitempty"`
+	ConvertedPlan     string     `json:"converted_plan,omitempty" gorm:"size:20"`
+	RewardUSD         float64    `json:"reward_usd"`
+	ReferredRewardUSD float64    `json:"referred_reward_usd"`
+	RewardedAt        *time.Time `json:"rewarded_at,omitempty" gorm:"index"`
+}
+
+// TableName specifies the table name for Referral
+func (Referral) TableName() string {
+	return "referrals"
+}
+
+// ReferralSummary is a referral as shown to the referrer, with the referred
+// user's name masked
+type ReferralSummary struct {
+	ID          uint       `json:"id"`
+	Username    string     `json:"username"`
+	Status      string     `json:"status"`
+	SignedUpAt  time.Time  `json:"signed_up_at"`
+	ConvertedAt *time.Time `json:"converted_at,omitempty"`
+	RewardUSD   float64    `json:"reward_usd"`
+}
+
+// Dashboard is a referrer's link with its click, signup and credit totals
+type Dashboard struct {
+	Code              string            `json:"code"`
+	Clicks            int64             `json:"clicks"`
+	UniqueClicks      int64             `json:"unique_clicks"`
+	Signups           int64             `json:"signups"`
+	Conversions       int64             `json:"conversions"`
+	Flagged           int64             `json:"flagged"`
+	EarnedCreditsUSD  float64           `json:"earned_credits_usd"`
+	RewardUSD         float64           `json:"reward_usd"`
+	ReferredRewardUSD float64           `json:"referred_reward_usd"`
+	MonthlyRewardCap  int               `json:"monthly_reward_cap"`
+	Referrals         []ReferralSummary `json:"referrals"`
+}
+
+// CookieName holds the referral code between clicking a link and signing up
+const CookieName = "apex_ref"
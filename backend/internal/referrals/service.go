@@ -0,0 +1,384 @@
+package referrals
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"apex-build/internal/payments"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Default rewards, issued as credits when a referred user first pays
+const (
+	DefaultReferrerRewardUSD  = 10.0
+	DefaultReferredRewardUSD  = 5.0
+	DefaultMonthlyRewardCap   = 20
+	codeLength                = 8
+	uniqueClickWindow         = 24 * time.Hour
+	dashboardRecentReferrals  = 50
+	codeGenerationMaxAttempts = 5
+)
+
+// codeAlphabet avoids characters that are easy to misread in a link
+const codeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+var (
+	ErrUnknownCode  = errors.New("unknown referral code")
+	ErrSelfReferral = errors.New("cannot refer yourself")
+)
+
+// Service manages referral codes, attribution and rewards
+type Service struct {
+	db                *gorm.DB
+	referrerRewardUSD float64
+	referredRewardUSD float64
+	monthlyRewardCap  int
+}
+
+// NewService creates a referral service with the default rewards
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:                db,
+		referrerRewardUSD: DefaultReferrerRewardUSD,
+		referredRewardUSD: DefaultReferredRewardUSD,
+		monthlyRewardCap:  DefaultMonthlyRewardCap,
+	}
+}
+
+// SetRewards overrides the credit rewards and the number of rewards a
+// referrer can earn per calendar month. A cap of 0 disables the cap.
+func (s *Service) SetRewards(referrerUSD, referredUSD float64, monthlyCap int) {
+	if referrerUSD >= 0 {
+		s.referrerRewardUSD = referrerUSD
+	}
+	if referredUSD >= 0 {
+		s.referredRewardUSD = referredUSD
+	}
+	if monthlyCap >= 0 {
+		s.monthlyRewardCap = monthlyCap
+	}
+}
+
+// hashValue hashes an IP address or device ID for storage. Empty values
+// stay empty so they never match each other.
+func hashValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("apex-referral:" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateCode() (string, error) {
+	buf := make([]byte, codeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = codeAlphabet[int(b)%len(codeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// NormalizeCode trims and lowercases a code from a link or form
+func NormalizeCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// CodeFor returns the user's referral code, creating it on first use
+func (s *Service) CodeFor(userID uint) (*Code, error) {
+	var code Code
+	err := s.db.Where("user_id = ?", userID).First(&code).Error
+	if err == nil {
+		return &code, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < codeGenerationMaxAttempts; attempt++ {
+		value, err := generateCode()
+		if err != nil {
+			return nil, err
+		}
+		code = Code{UserID: userID, Code: value}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&code)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return &code, nil
+		}
+		// Either a concurrent request created this user's code or the
+		// random code collided with another user's
+		if err := s.db.Where("user_id = ?", userID).First(&code).Error; err == nil {
+			return &code, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to generate a unique referral code")
+}
+
+// lookupCode resolves a code to its owner
+func (s *Service) lookupCode(value string) (*Code, error) {
+	value = NormalizeCode(value)
+	if value == "" || len(value) > 16 {
+		return nil, ErrUnknownCode
+	}
+	var code Code
+	if err := s.db.Where("code = ?", value).First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownCode
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RecordClick counts a visit to a referral link
+func (s *Service) RecordClick(value, ip, deviceID string) (*Code, error) {
+	code, err := s.lookupCode(value)
+	if err != nil {
+		return nil, err
+	}
+	ipHash := hashValue(ip)
+	var recent int64
+	if ipHash != "" {
+		s.db.Model(&Click{}).
+			Where("referrer_id = ? AND ip_hash = ? AND created_at > ?", code.UserID, ipHash, time.Now().Add(-uniqueClickWindow)).
+			Count(&recent)
+	}
+	click := &Click{
+		ReferrerID: code.UserID,
+		IPHash:     ipHash,
+		DeviceHash: hashValue(deviceID),
+		IsUnique:   recent == 0,
+	}
+	if err := s.db.Create(click).Error; err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// AttributeSignup links a new user to the referrer whose code they signed
+// up with. Signups that share an IP address or device with the referrer's
+// own sessions, or with the referrer's earlier referrals, are flagged and
+// will not earn rewards. Attributing the same user twice returns the
+// original referral.
+func (s *Service) AttributeSignup(userID uint, value, ip, deviceID string) (*Referral, error) {
+	code, err := s.lookupCode(value)
+	if err != nil {
+		return nil, err
+	}
+	if code.UserID == userID {
+		return nil, ErrSelfReferral
+	}
+
+	var existing Referral
+	if err := s.db.Where("referred_user_id = ?", userID).First(&existing).Error; err == nil {
+		return &existing, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	referral := &Referral{
+		ReferrerID:       code.UserID,
+		ReferredUserID:   userID,
+		Status:           StatusPending,
+		SignupIPHash:     hashValue(ip),
+		SignupDeviceHash: hashValue(deviceID),
+	}
+	if reason := s.detectAbuse(code.UserID, ip, deviceID, referral); reason != "" {
+		referral.Status = StatusFlagged
+		referral.FlagReason = reason
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(referral)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := s.db.Where("referred_user_id = ?", userID).First(&existing).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+	return referral, nil
+}
+
+// detectAbuse returns why a signup looks like the referrer signing up
+// themselves, or "" when it does not
+func (s *Service) detectAbuse(referrerID uint, ip, deviceID string, referral *Referral) string {
+	exists := func(query *gorm.DB) bool {
+		var n int64
+		if err := query.Limit(1).Count(&n).Error; err != nil {
+			return false
+		}
+		return n > 0
+	}
+	ip = strings.TrimSpace(ip)
+	deviceID = strings.TrimSpace(deviceID)
+
+	if ip != "" && exists(s.db.Model(&models.RefreshToken{}).Unscoped().Where("user_id = ? AND ip_address = ?", referrerID, ip)) {
+		return FlagSameIP
+	}
+	if deviceID != "" && exists(s.db.Model(&models.RefreshToken{}).Unscoped().Where("user_id = ? AND device_id = ?", referrerID, deviceID)) {
+		return FlagSameDevice
+	}
+	if referral.SignupIPHash != "" && exists(s.db.Model(&Referral{}).Where("referrer_id = ? AND signup_ip_hash = ?", referrerID, referral.SignupIPHash)) {
+		return FlagRepeatIP
+	}
+	if referral.SignupDeviceHash != "" && exists(s.db.Model(&Referral{}).Where("referrer_id = ? AND signup_device_hash = ?", referrerID, referral.SignupDeviceHash)) {
+		return FlagRepeatDevice
+	}
+	return ""
+}
+
+// RecordConversion issues referral rewards the first time a referred user
+// pays for a plan. It is safe to call on every paid invoice: only a pending
+// referral is rewarded, once.
+func (s *Service) RecordConversion(userID uint, plan string) error {
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var referral Referral
+		if err := tx.Where("referred_user_id = ?", userID).First(&referral).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if referral.ConvertedAt != nil {
+			return nil
+		}
+
+		status := referral.Status
+		if status == StatusPending {
+			status = StatusRewarded
+			if s.monthlyRewardCap > 0 {
+				monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+				var rewarded int64
+				tx.Model(&Referral{}).
+					Where("referrer_id = ? AND status = ? AND rewarded_at >= ?", referral.ReferrerID, StatusRewarded, monthStart).
+					Count(&rewarded)
+				if rewarded >= int64(s.monthlyRewardCap) {
+					status = StatusCapped
+				}
+			}
+		}
+
+		updates := map[string]interface{}{
+			"status":         status,
+			"converted_at":   now,
+			"converted_plan": plan,
+		}
+		if status == StatusRewarded {
+			updates["reward_usd"] = s.referrerRewardUSD
+			updates["referred_reward_usd"] = s.referredRewardUSD
+			updates["rewarded_at"] = now
+		}
+		// The converted_at guard makes concurrent invoice webhooks race
+		// safely: only one of them claims the conversion
+		result := tx.Model(&Referral{}).
+			Where("id = ? AND converted_at IS NULL", referral.ID).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || status != StatusRewarded {
+			return nil
+		}
+
+		if s.referrerRewardUSD > 0 {
+			if err := payments.ApplyCreditGrant(tx, referral.ReferrerID, s.referrerRewardUSD,
+				payments.CreditEntryTypeReferralReward,
+				fmt.Sprintf("Referral reward — a user you invited upgraded to %s", plan),
+				"", "", plan); err != nil {
+				return err
+			}
+		}
+		if s.referredRewardUSD > 0 {
+			if err := payments.ApplyCreditGrant(tx, referral.ReferredUserID, s.referredRewardUSD,
+				payments.CreditEntryTypeReferralReward,
+				"Referral bonus for upgrading through an invite link",
+				"", "", plan); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// maskUsername keeps the first and last characters of a username
+func maskUsername(username string) string {
+	runes := []rune(username)
+	switch {
+	case len(runes) == 0:
+		return "deleted user"
+	case len(runes) <= 2:
+		return string(runes[0]) + "***"
+	default:
+		return string(runes[0]) + "***" + string(runes[len(runes)-1])
+	}
+}
+
+// Dashboard returns the referrer's link with clicks, signups and earned
+// credits, and their most recent referrals
+func (s *Service) Dashboard(userID uint) (*Dashboard, error) {
+	code, err := s.CodeFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	dashboard := &Dashboard{
+		Code:              code.Code,
+		RewardUSD:         s.referrerRewardUSD,
+		ReferredRewardUSD: s.referredRewardUSD,
+		MonthlyRewardCap:  s.monthlyRewardCap,
+		Referrals:         []ReferralSummary{},
+	}
+
+	s.db.Model(&Click{}).Where("referrer_id = ?", userID).Count(&dashboard.Clicks)
+	s.db.Model(&Click{}).Where("referrer_id = ? AND is_unique = ?", userID, true).Count(&dashboard.UniqueClicks)
+	s.db.Model(&Referral{}).Where("referrer_id = ?", userID).Count(&dashboard.Signups)
+	s.db.Model(&Referral{}).Where("referrer_id = ? AND converted_at IS NOT NULL", userID).Count(&dashboard.Conversions)
+	s.db.Model(&Referral{}).Where("referrer_id = ? AND status = ?", userID, StatusFlagged).Count(&dashboard.Flagged)
+	s.db.Model(&Referral{}).Where("referrer_id = ? AND status = ?", userID, StatusRewarded).
+		Select("COALESCE(SUM(reward_usd), 0)").Scan(&dashboard.EarnedCreditsUSD)
+
+	var rows []struct {
+		Referral
+		Username string
+	}
+	err = s.db.Table("referrals").
+		Select("referrals.*, users.username AS username").
+		Joins("LEFT JOIN users ON users.id = referrals.referred_user_id").
+		Where("referrals.referrer_id = ?", userID).
+		Order("referrals.created_at DESC").
+		Limit(dashboardRecentReferrals).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		dashboard.Referrals = append(dashboard.Referrals, ReferralSummary{
+			ID:          row.ID,
+			Username:    maskUsername(row.Username),
+			Status:      row.Status,
+			SignedUpAt:  row.CreatedAt,
+			ConvertedAt: row.ConvertedAt,
+			RewardUSD:   row.RewardUSD,
+		})
+	}
+	return dashboard, nil
+}
+
+// AutoMigrate creates the referral tables
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Code{}, &Click{}, &Referral{})
+}
@@ -0,0 +1,171 @@
+package referrals
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.CreditLedgerEntry{}, &models.ProcessedStripeEvent{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate referrals: %v", err)
+	}
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestReferralConversionRewardsOnce(t *testing.T) {
+	db := openTestDB(t)
+	referrer := createUser(t, db, "ada")
+	referred := createUser(t, db, "grace")
+	service := NewService(db)
+
+	code, err := service.CodeFor(referrer.ID)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if again, _ := service.CodeFor(referrer.ID); again.Code != code.Code || len(code.Code) != codeLength {
+		t.Fatalf("codes = %q and %q", code.Code, again.Code)
+	}
+
+	for _, ip := range []string{"203.0.113.7", "203.0.113.7", "198.51.100.2"} {
+		if _, err := service.RecordClick(code.Code, ip, ""); err != nil {
+			t.Fatalf("click: %v", err)
+		}
+	}
+	if _, err := service.RecordClick("nope", "203.0.113.7", ""); !errors.Is(err, ErrUnknownCode) {
+		t.Fatalf("unknown code click err = %v", err)
+	}
+	if _, err := service.AttributeSignup(referrer.ID, code.Code, "203.0.113.7", ""); !errors.Is(err, ErrSelfReferral) {
+		t.Fatalf("self referral err = %v", err)
+	}
+
+	referral, err := service.AttributeSignup(referred.ID, " "+code.Code+" ", "203.0.113.7", "device-b")
+	if err != nil {
+		t.Fatalf("attribute: %v", err)
+	}
+	if referral.Status != StatusPending {
+		t.Fatalf("status = %q, want pending", referral.Status)
+	}
+
+	if err := service.RecordConversion(referred.ID, "pro"); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if err := service.RecordConversion(referred.ID, "pro"); err != nil {
+		t.Fatalf("second convert: %v", err)
+	}
+
+	var balance float64
+	db.Model(&models.User{}).Where("id = ?", referrer.ID).Select("credit_balance").Scan(&balance)
+	if balance != DefaultReferrerRewardUSD {
+		t.Fatalf("referrer balance = %v, want %v", balance, DefaultReferrerRewardUSD)
+	}
+	var entries int64
+	db.Model(&models.CreditLedgerEntry{}).Where("entry_type = ?", "referral_reward").Count(&entries)
+	if entries != 2 {
+		t.Fatalf("ledger entries = %d, want one each for referrer and referred", entries)
+	}
+
+	dashboard, err := service.Dashboard(referrer.ID)
+	if err != nil {
+		t.Fatalf("dashboard: %v", err)
+	}
+	if dashboard.Clicks != 3 || dashboard.UniqueClicks != 2 || dashboard.Signups != 1 || dashboard.Conversions != 1 || dashboard.EarnedCreditsUSD != DefaultReferrerRewardUSD {
+		t.Fatalf("dashboard = %+v", dashboard)
+	}
+	if len(dashboard.Referrals) != 1 || dashboard.Referrals[0].Username != "g***e" || dashboard.Referrals[0].Status != StatusRewarded {
+		t.Fatalf("referrals = %+v", dashboard.Referrals)
+	}
+}
+
+func TestReferralAbuseIsFlagged(t *testing.T) {
+	db := openTestDB(t)
+	referrer := createUser(t, db, "ada")
+	service := NewService(db)
+	code, err := service.CodeFor(referrer.ID)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	session := &models.RefreshToken{Token: "t", TokenHash: "h", UserID: referrer.ID, ExpiresAt: time.Now().Add(time.Hour), IssuedAt: time.Now(), IPAddress: "203.0.113.7", DeviceID: "laptop", FamilyID: "f"}
+	if err := db.Create(session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	cases := []struct {
+		name, ip, device, flag string
+	}{
+		{"sameip", "203.0.113.7", "", FlagSameIP},
+		{"samedevice", "198.51.100.2", "laptop", FlagSameDevice},
+		{"first", "192.0.2.10", "phone-1", ""},
+		{"repeatip", "192.0.2.10", "phone-2", FlagRepeatIP},
+		{"repeatdevice", "192.0.2.11", "phone-1", FlagRepeatDevice},
+	}
+	for _, tc := range cases {
+		user := createUser(t, db, tc.name)
+		referral, err := service.AttributeSignup(user.ID, code.Code, tc.ip, tc.device)
+		if err != nil {
+			t.Fatalf("%s: attribute: %v", tc.name, err)
+		}
+		if referral.FlagReason != tc.flag {
+			t.Fatalf("%s: flag = %q, want %q", tc.name, referral.FlagReason, tc.flag)
+		}
+		if err := service.RecordConversion(user.ID, "pro"); err != nil {
+			t.Fatalf("%s: convert: %v", tc.name, err)
+		}
+	}
+
+	var rewarded int64
+	db.Model(&Referral{}).Where("status = ?", StatusRewarded).Count(&rewarded)
+	if rewarded != 1 {
+		t.Fatalf("rewarded referrals = %d, want only the clean signup", rewarded)
+	}
+}
+
+func TestReferralMonthlyCap(t *testing.T) {
+	db := openTestDB(t)
+	referrer := createUser(t, db, "ada")
+	service := NewService(db)
+	service.SetRewards(10, 0, 1)
+	code, err := service.CodeFor(referrer.ID)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+
+	var statuses []string
+	for i, name := range []string{"first", "second"} {
+		user := createUser(t, db, name)
+		if _, err := service.AttributeSignup(user.ID, code.Code, "192.0.2."+string(rune('1'+i)), ""); err != nil {
+			t.Fatalf("attribute: %v", err)
+		}
+		if err := service.RecordConversion(user.ID, "builder"); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		var referral Referral
+		db.Where("referred_user_id = ?", user.ID).First(&referral)
+		statuses = append(statuses, referral.Status)
+	}
+	if statuses[0] != StatusRewarded || statuses[1] != StatusCapped {
+		t.Fatalf("statuses = %v, want rewarded then capped", statuses)
+	}
+}
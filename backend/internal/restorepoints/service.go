@@ -0,0 +1,591 @@
+// Package restorepoints snapshots a whole project's file tree so it can be
+// compared and rolled back as a unit, where file versions only cover one
+// file at a time. Points are taken automatically before a build's artifacts
+// are applied, before git pulls and bulk replacements, and before every
+// restore, and can be created on request.
+package restorepoints
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reasons a restore point was taken.
+const (
+	ReasonManual  = "manual"
+	ReasonBuild   = "build"
+	ReasonImport  = "import"
+	ReasonBulk    = "bulk"
+	ReasonRestore = "restore"
+)
+
+// Change statuses in a diff.
+const (
+	StatusAdded    = "added"
+	StatusRemoved  = "removed"
+	StatusModified = "modified"
+)
+
+var (
+	// ErrNotFound is returned for restore points outside the project.
+	ErrNotFound = errors.New("restore point not found")
+	// ErrInvalidDirectory is returned for directories that escape the project root.
+	ErrInvalidDirectory = errors.New("invalid directory")
+	// ErrTagExists is returned when another restore point of the project has the tag.
+	ErrTagExists = errors.New("tag already in use")
+)
+
+// Point is a snapshot of a project's files at one moment.
+type Point struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	ProjectID   uint      `gorm:"not null;index" json:"project_id"`
+	Reason      string    `gorm:"not null;size:32" json:"reason"`
+	Label       string    `gorm:"size:255" json:"label"`
+	CreatedByID uint      `json:"created_by_id"`
+	FileCount   int       `json:"file_count"`
+	TotalSize   int64     `json:"total_size"`
+
+	// Named tag, unique per project. Protected points are never pruned, and
+	// only project admins may restore or delete them.
+	Tag         string     `gorm:"size:100;index" json:"tag,omitempty"`
+	IsProtected bool       `json:"is_protected"`
+	TaggedByID  *uint      `json:"tagged_by_id,omitempty"`
+	TaggedAt    *time.Time `json:"tagged_at,omitempty"`
+}
+
+func (Point) TableName() string { return "project_restore_points" }
+
+// Entry is one file or directory in a restore point. Text content is stored
+// once per distinct hash in Blob; binary files keep their blob store hash.
+type Entry struct {
+	ID          uint   `gorm:"primarykey" json:"-"`
+	PointID     uint   `gorm:"not null;index" json:"-"`
+	Path        string `gorm:"not null;size:1024" json:"path"`
+	Name        string `gorm:"size:255" json:"name"`
+	Type        string `gorm:"size:16" json:"type"`
+	MimeType    string `gorm:"size:255" json:"mime_type,omitempty"`
+	ContentHash string `gorm:"size:64" json:"content_hash"`
+	Size        int64  `json:"size"`
+	IsBinary    bool   `json:"is_binary"`
+	BinaryHash  string `gorm:"size:128" json:"-"`
+}
+
+func (Entry) TableName() string { return "project_restore_point_files" }
+
+// Blob holds text content shared by every entry with the same hash.
+type Blob struct {
+	Hash      string `gorm:"primarykey;size:64"`
+	CreatedAt time.Time
+	Content   string `gorm:"type:text"`
+}
+
+func (Blob) TableName() string { return "project_restore_point_blobs" }
+
+// Change is one path that differs between two trees.
+type Change struct {
+	Path         string `json:"path"`
+	Status       string `json:"status"`
+	LinesAdded   int    `json:"lines_added"`
+	LinesRemoved int    `json:"lines_removed"`
+}
+
+// RestoreResult describes a completed restore.
+type RestoreResult struct {
+	Point         *Point `json:"point"`
+	SafetyPointID uint   `json:"safety_point_id"` // taken just before restoring, to undo it
+	Directory     string `json:"directory,omitempty"`
+	Updated       int    `json:"updated"`
+	Created       int    `json:"created"`
+	Deleted       int    `json:"deleted"`
+}
+
+// VersionRecorder records a file version for a file a restore changed.
+// Implemented by handlers.CreateFileVersion.
+type VersionRecorder func(db *gorm.DB, file *models.File, authorID uint, authorName, changeType, summary string) uint
+
+// Service creates, compares and restores project restore points.
+type Service struct {
+	db       *gorm.DB
+	versions VersionRecorder
+}
+
+// NewService creates a new restore point Service backed by the given database.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// AutoMigrate creates the restore point tables.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Point{}, &Entry{}, &Blob{})
+}
+
+// SetVersionRecorder makes restores record a file version for each file they change.
+func (s *Service) SetVersionRecorder(r VersionRecorder) { s.versions = r }
+
+// Create takes a restore point of the project's current files.
+func (s *Service) Create(ctx context.Context, projectID, userID uint, reason, label string) (*Point, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("restorepoints: database unavailable")
+	}
+	return s.snapshot(s.db.WithContext(ctx), projectID, userID, reason, label)
+}
+
+// SnapshotProject takes a restore point inside the caller's transaction, so
+// it sees the tree the caller is about to overwrite.
+func (s *Service) SnapshotProject(tx *gorm.DB, projectID, userID uint, reason, label string) error {
+	if s == nil || tx == nil {
+		return fmt.Errorf("restorepoints: database unavailable")
+	}
+	_, err := s.snapshot(tx, projectID, userID, reason, label)
+	return err
+}
+
+func (s *Service) snapshot(db *gorm.DB, projectID, userID uint, reason, label string) (*Point, error) {
+	var files []models.File
+	if err := db.Where("project_id = ?", projectID).Order("path").Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("restorepoints: load files: %w", err)
+	}
+
+	point := &Point{ProjectID: projectID, Reason: reason, Label: label, CreatedByID: userID}
+	entries := make([]Entry, 0, len(files))
+	blobs := make(map[string]string)
+	for _, file := range files {
+		entry := Entry{
+			Path:     file.Path,
+			Name:     file.Name,
+			Type:     file.Type,
+			MimeType: file.MimeType,
+			Size:     file.Size,
+			IsBinary: file.IsBinary,
+		}
+		if file.IsBinary {
+			entry.BinaryHash = file.Hash
+		} else if file.Type != "directory" {
+			entry.ContentHash = contentHash(file.Content)
+			blobs[entry.ContentHash] = file.Content
+		}
+		if file.Type != "directory" {
+			point.FileCount++
+			point.TotalSize += file.Size
+		}
+		entries = append(entries, entry)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(point).Error; err != nil {
+			return err
+		}
+		for hash, content := range blobs {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&Blob{Hash: hash, Content: content}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range entries {
+			entries[i].PointID = point.ID
+		}
+		if len(entries) > 0 {
+			return tx.CreateInBatches(entries, 200).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restorepoints: create: %w", err)
+	}
+	return point, nil
+}
+
+// List returns a project's restore points, newest first, optionally only
+// the tagged ones.
+func (s *Service) List(ctx context.Context, projectID uint, limit int, taggedOnly bool) ([]Point, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("restorepoints: database unavailable")
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	q := s.db.WithContext(ctx).Where("project_id = ?", projectID)
+	if taggedOnly {
+		q = q.Where("tag <> ''")
+	}
+	var points []Point
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&points).Error; err != nil {
+		return nil, fmt.Errorf("restorepoints: list: %w", err)
+	}
+	return points, nil
+}
+
+// Get returns one of the project's restore points.
+func (s *Service) Get(ctx context.Context, projectID, pointID uint) (*Point, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("restorepoints: database unavailable")
+	}
+	var point Point
+	err := s.db.WithContext(ctx).Where("id = ? AND project_id = ?", pointID, projectID).First(&point).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restorepoints: get: %w", err)
+	}
+	return &point, nil
+}
+
+// Tag names a restore point, or clears its tag when tag is empty. Clearing
+// the tag also lifts its protection.
+func (s *Service) Tag(ctx context.Context, projectID, pointID, userID uint, tag string, protected bool) (*Point, error) {
+	point, err := s.Get(ctx, projectID, pointID)
+	if err != nil {
+		return nil, err
+	}
+	db := s.db.WithContext(ctx)
+	if tag != "" {
+		var clash int64
+		if err := db.Model(&Point{}).Where("project_id = ? AND tag = ? AND id <> ?", projectID, tag, pointID).
+			Count(&clash).Error; err != nil {
+			return nil, fmt.Errorf("restorepoints: check tag: %w", err)
+		}
+		if clash > 0 {
+			return nil, ErrTagExists
+		}
+		now := time.Now()
+		point.Tag, point.IsProtected, point.TaggedByID, point.TaggedAt = tag, protected, &userID, &now
+	} else {
+		point.Tag, point.IsProtected, point.TaggedByID, point.TaggedAt = "", false, nil, nil
+	}
+	if err := db.Model(point).Select("tag", "is_protected", "tagged_by_id", "tagged_at").Updates(point).Error; err != nil {
+		return nil, fmt.Errorf("restorepoints: tag: %w", err)
+	}
+	return point, nil
+}
+
+// Delete removes a restore point and its file entries. Content blobs are
+// left for other points that share them.
+func (s *Service) Delete(ctx context.Context, projectID, pointID uint) error {
+	if _, err := s.Get(ctx, projectID, pointID); err != nil {
+		return err
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("point_id = ?", pointID).Delete(&Entry{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Point{}, pointID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("restorepoints: delete: %w", err)
+	}
+	return nil
+}
+
+// Diff compares two restore points of a project, optionally within one
+// directory. A toID of 0 compares against the project's current files.
+func (s *Service) Diff(ctx context.Context, projectID, fromID, toID uint, dir string) ([]Change, error) {
+	dir, err := cleanDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.loadTree(ctx, projectID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	var to map[string]treeFile
+	if toID == 0 {
+		to, err = s.currentTree(s.db.WithContext(ctx), projectID)
+	} else {
+		to, err = s.loadTree(ctx, projectID, toID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for p, old := range from {
+		if !inDirectory(p, dir) || old.Type == "directory" {
+			continue
+		}
+		cur, ok := to[p]
+		switch {
+		case !ok || cur.Type == "directory":
+			changes = append(changes, Change{Path: p, Status: StatusRemoved, LinesRemoved: countLines(old.Content)})
+		case old.key() != cur.key():
+			added, removed := lineChanges(old.Content, cur.Content)
+			changes = append(changes, Change{Path: p, Status: StatusModified, LinesAdded: added, LinesRemoved: removed})
+		}
+	}
+	for p, cur := range to {
+		if !inDirectory(p, dir) || cur.Type == "directory" {
+			continue
+		}
+		if old, ok := from[p]; !ok || old.Type == "directory" {
+			changes = append(changes, Change{Path: p, Status: StatusAdded, LinesAdded: countLines(cur.Content)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// Restore puts the project, or one directory of it, back to a restore
+// point. Files changed since are overwritten, missing files recreated and
+// files added since are deleted (they stay in the trash). A restore point of
+// the tree as it was is taken first, so the restore itself can be undone.
+func (s *Service) Restore(ctx context.Context, projectID, pointID, userID uint, userName, dir string) (*RestoreResult, error) {
+	dir, err := cleanDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	point, err := s.Get(ctx, projectID, pointID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.loadTree(ctx, projectID, pointID)
+	if err != nil {
+		return nil, err
+	}
+
+	label := fmt.Sprintf("Before restoring to #%d", point.ID)
+	if dir != "" {
+		label = fmt.Sprintf("Before restoring %s to #%d", dir, point.ID)
+	}
+	result := &RestoreResult{Point: point, Directory: dir}
+	summary := fmt.Sprintf("Restored from restore point #%d", point.ID)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		safety, err := s.snapshot(tx, projectID, userID, ReasonRestore, label)
+		if err != nil {
+			return err
+		}
+		result.SafetyPointID = safety.ID
+
+		var current []models.File
+		if err := tx.Where("project_id = ?", projectID).Find(&current).Error; err != nil {
+			return err
+		}
+		currentByPath := make(map[string]*models.File, len(current))
+		for i := range current {
+			currentByPath[normalizePath(current[i].Path)] = &current[i]
+		}
+
+		for p, want := range target {
+			if !inDirectory(p, dir) {
+				continue
+			}
+			file, ok := currentByPath[p]
+			if !ok {
+				created := want.file(projectID, userID)
+				if err := tx.Create(created).Error; err != nil {
+					return err
+				}
+				result.Created++
+				s.recordVersion(tx, created, userID, userName, summary)
+				continue
+			}
+			if file.Type == want.Type && treeFileOf(file).key() == want.key() {
+				continue
+			}
+			if err := tx.Model(&models.File{}).Where("id = ?", file.ID).Updates(map[string]interface{}{
+				"type":         want.Type,
+				"mime_type":    want.MimeType,
+				"content":      want.Content,
+				"size":         want.Size,
+				"is_binary":    want.IsBinary,
+				"hash":         want.BinaryHash,
+				"last_edit_by": userID,
+				"version":      gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+			file.Type, file.Content, file.Size, file.IsBinary = want.Type, want.Content, want.Size, want.IsBinary
+			result.Updated++
+			s.recordVersion(tx, file, userID, userName, summary)
+		}
+
+		for p, file := range currentByPath {
+			if _, keep := target[p]; keep || !inDirectory(p, dir) {
+				continue
+			}
+			if err := tx.Delete(&models.File{}, file.ID).Error; err != nil {
+				return err
+			}
+			result.Deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restorepoints: restore: %w", err)
+	}
+	return result, nil
+}
+
+func (s *Service) recordVersion(tx *gorm.DB, file *models.File, userID uint, userName, summary string) {
+	if s.versions != nil && file.Type != "directory" && !file.IsBinary {
+		s.versions(tx, file, userID, userName, "restore", summary)
+	}
+}
+
+// treeFile is a file as stored in a point or in the files table.
+type treeFile struct {
+	Entry
+	Content string
+}
+
+func (f treeFile) key() string {
+	if f.IsBinary {
+		return "bin:" + f.BinaryHash
+	}
+	return f.ContentHash
+}
+
+func (f treeFile) file(projectID, userID uint) *models.File {
+	file := &models.File{
+		ProjectID:  projectID,
+		Path:       f.Path,
+		Name:       f.Name,
+		Type:       f.Type,
+		MimeType:   f.MimeType,
+		Content:    f.Content,
+		Size:       f.Size,
+		IsBinary:   f.IsBinary,
+		Hash:       f.BinaryHash,
+		LastEditBy: userID,
+	}
+	if file.Name == "" {
+		file.Name = path.Base(f.Path)
+	}
+	return file
+}
+
+func treeFileOf(file *models.File) treeFile {
+	f := treeFile{Entry: Entry{
+		Path:     file.Path,
+		Name:     file.Name,
+		Type:     file.Type,
+		MimeType: file.MimeType,
+		Size:     file.Size,
+		IsBinary: file.IsBinary,
+	}, Content: file.Content}
+	if file.IsBinary {
+		f.BinaryHash = file.Hash
+	} else if file.Type != "directory" {
+		f.ContentHash = contentHash(file.Content)
+	}
+	return f
+}
+
+func (s *Service) loadTree(ctx context.Context, projectID, pointID uint) (map[string]treeFile, error) {
+	if _, err := s.Get(ctx, projectID, pointID); err != nil {
+		return nil, err
+	}
+	db := s.db.WithContext(ctx)
+	var entries []Entry
+	if err := db.Where("point_id = ?", pointID).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("restorepoints: load entries: %w", err)
+	}
+	hashes := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ContentHash != "" {
+			hashes = append(hashes, entry.ContentHash)
+		}
+	}
+	contents := make(map[string]string, len(hashes))
+	for start := 0; start < len(hashes); start += 500 {
+		end := start + 500
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		var blobs []Blob
+		if err := db.Where("hash IN ?", hashes[start:end]).Find(&blobs).Error; err != nil {
+			return nil, fmt.Errorf("restorepoints: load content: %w", err)
+		}
+		for _, blob := range blobs {
+			contents[blob.Hash] = blob.Content
+		}
+	}
+
+	tree := make(map[string]treeFile, len(entries))
+	for _, entry := range entries {
+		tree[normalizePath(entry.Path)] = treeFile{Entry: entry, Content: contents[entry.ContentHash]}
+	}
+	return tree, nil
+}
+
+func (s *Service) currentTree(db *gorm.DB, projectID uint) (map[string]treeFile, error) {
+	var files []models.File
+	if err := db.Where("project_id = ?", projectID).Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("restorepoints: load files: %w", err)
+	}
+	tree := make(map[string]treeFile, len(files))
+	for i := range files {
+		tree[normalizePath(files[i].Path)] = treeFileOf(&files[i])
+	}
+	return tree, nil
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizePath(p string) string {
+	return strings.Trim(p, "/")
+}
+
+// cleanDirectory normalizes a directory filter; "" means the whole project
+func cleanDirectory(dir string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" || dir == "/" || dir == "." {
+		return "", nil
+	}
+	cleaned := path.Clean("/" + dir)
+	if cleaned != "/"+strings.Trim(dir, "/") {
+		return "", ErrInvalidDirectory
+	}
+	return strings.Trim(cleaned, "/"), nil
+}
+
+func inDirectory(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	return strings.Count(content, "\n") + 1
+}
+
+// lineChanges counts lines only in the new content and only in the old,
+// ignoring order
+func lineChanges(oldContent, newContent string) (added, removed int) {
+	counts := make(map[string]int)
+	if oldContent != "" {
+		for _, line := range strings.Split(oldContent, "\n") {
+			counts[line]++
+		}
+	}
+	if newContent != "" {
+		for _, line := range strings.Split(newContent, "\n") {
+			if counts[line] > 0 {
+				counts[line]--
+			} else {
+				added++
+			}
+		}
+	}
+	for _, n := range counts {
+		removed += n
+	}
+	return added, removed
+}
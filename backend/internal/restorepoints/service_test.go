@@ -0,0 +1,177 @@
+package restorepoints
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB, uint) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate restore points: %v", err)
+	}
+	owner := models.User{Username: "ada", Email: "ada@example.com", PasswordHash: "x"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	project := models.Project{Name: "app", Language: "go", OwnerID: owner.ID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	for _, file := range []models.File{
+		{Path: "main.go", Name: "main.go", Type: "file", Content: "package main\n\nfunc main() {}\n"},
+		{Path: "src", Name: "src", Type: "directory"},
+		{Path: "src/a.go", Name: "a.go", Type: "file", Content: "package src\n\nvar A = 1\n"},
+		{Path: "src/b.go", Name: "b.go", Type: "file", Content: "package src\n\nvar B = 2\n"},
+	} {
+		file.ProjectID = project.ID
+		file.Version = 1
+		if err := db.Create(&file).Error; err != nil {
+			t.Fatalf("create %s: %v", file.Path, err)
+		}
+	}
+	return NewService(db), db, project.ID
+}
+
+func fileContent(t *testing.T, db *gorm.DB, projectID uint, path string) (string, bool) {
+	t.Helper()
+	var file models.File
+	err := db.Where("project_id = ? AND path = ?", projectID, path).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false
+	}
+	if err != nil {
+		t.Fatalf("load %s: %v", path, err)
+	}
+	return file.Content, true
+}
+
+func TestDiffAndRestoreDirectory(t *testing.T) {
+	service, db, projectID := newTestService(t)
+	var recorded []string
+	service.SetVersionRecorder(func(_ *gorm.DB, file *models.File, _ uint, _, changeType, _ string) uint {
+		recorded = append(recorded, changeType+":"+file.Path)
+		return 0
+	})
+	ctx := context.Background()
+
+	point, err := service.Create(ctx, projectID, 1, ReasonBuild, "Before build")
+	if err != nil {
+		t.Fatalf("create point: %v", err)
+	}
+	if point.FileCount != 3 {
+		t.Fatalf("file count = %d, want 3", point.FileCount)
+	}
+
+	// Edit, delete and add files, in and out of src
+	db.Model(&models.File{}).Where("path = ?", "src/a.go").Update("content", "package src\n\nvar A = 10\n")
+	db.Model(&models.File{}).Where("path = ?", "main.go").Update("content", "package main\n")
+	db.Where("path = ?", "src/b.go").Delete(&models.File{})
+	db.Create(&models.File{ProjectID: projectID, Path: "src/c.go", Name: "c.go", Type: "file", Content: "package src\n"})
+
+	changes, err := service.Diff(ctx, projectID, point.ID, 0, "src")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []Change{
+		{Path: "src/a.go", Status: StatusModified, LinesAdded: 1, LinesRemoved: 1},
+		{Path: "src/b.go", Status: StatusRemoved, LinesRemoved: 4},
+		{Path: "src/c.go", Status: StatusAdded, LinesAdded: 2},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	result, err := service.Restore(ctx, projectID, point.ID, 1, "ada", "/src/")
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if result.Updated != 1 || result.Created != 1 || result.Deleted != 1 || result.SafetyPointID == 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	if content, _ := fileContent(t, db, projectID, "src/a.go"); content != "package src\n\nvar A = 1\n" {
+		t.Fatalf("src/a.go = %q", content)
+	}
+	if _, ok := fileContent(t, db, projectID, "src/b.go"); !ok {
+		t.Fatal("src/b.go was not recreated")
+	}
+	if _, ok := fileContent(t, db, projectID, "src/c.go"); ok {
+		t.Fatal("src/c.go was not deleted")
+	}
+	if content, _ := fileContent(t, db, projectID, "main.go"); content != "package main\n" {
+		t.Fatalf("main.go outside the directory changed: %q", content)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("versions recorded = %v", recorded)
+	}
+
+	// The safety point taken before restoring can undo it
+	if _, err := service.Restore(ctx, projectID, result.SafetyPointID, 1, "ada", ""); err != nil {
+		t.Fatalf("undo restore: %v", err)
+	}
+	if _, ok := fileContent(t, db, projectID, "src/c.go"); !ok {
+		t.Fatal("src/c.go was not brought back by undo")
+	}
+}
+
+func TestRestoreRejectsOtherProjectsAndBadDirectories(t *testing.T) {
+	service, _, projectID := newTestService(t)
+	ctx := context.Background()
+	point, err := service.Create(ctx, projectID, 1, ReasonManual, "")
+	if err != nil {
+		t.Fatalf("create point: %v", err)
+	}
+	if _, err := service.Restore(ctx, projectID+1, point.ID, 1, "ada", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("restore into another project: err = %v", err)
+	}
+	if _, err := service.Diff(ctx, projectID, point.ID, 0, "../etc"); !errors.Is(err, ErrInvalidDirectory) {
+		t.Fatalf("diff outside the project: err = %v", err)
+	}
+}
+
+func TestTagIsUniquePerProject(t *testing.T) {
+	service, _, projectID := newTestService(t)
+	ctx := context.Background()
+	first, _ := service.Create(ctx, projectID, 1, ReasonManual, "")
+	second, _ := service.Create(ctx, projectID, 1, ReasonBuild, "")
+
+	tagged, err := service.Tag(ctx, projectID, first.ID, 1, "v1.0-submitted", true)
+	if err != nil || !tagged.IsProtected || tagged.TaggedAt == nil {
+		t.Fatalf("tag: %+v, %v", tagged, err)
+	}
+	if _, err := service.Tag(ctx, projectID, second.ID, 1, "v1.0-submitted", true); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("duplicate tag: err = %v", err)
+	}
+	points, err := service.List(ctx, projectID, 0, true)
+	if err != nil || len(points) != 1 || points[0].ID != first.ID {
+		t.Fatalf("tagged points = %+v, %v", points, err)
+	}
+
+	if _, err := service.Tag(ctx, projectID, first.ID, 1, "", false); err != nil {
+		t.Fatalf("untag: %v", err)
+	}
+	if err := service.Delete(ctx, projectID, first.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := service.Get(ctx, projectID, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted point: err = %v", err)
+	}
+}
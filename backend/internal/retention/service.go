@@ -0,0 +1,600 @@
+// Package retention prunes version history and completed build payloads so
+// they stop growing without bound. Each plan keeps file versions, project
+// restore points and build artifacts for a set time (and versions up to a
+// count per file); admins can override that per user or per project, or
+// exempt them. Pinned and protected versions and protected restore points
+// are always kept, as is the newest version of every file.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"apex-build/internal/restorepoints"
+	"apex-build/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// DefaultPruneInterval is how often the pruning job runs.
+const DefaultPruneInterval = 24 * time.Hour
+
+// Override scopes.
+const (
+	ScopeUser    = "user"
+	ScopeProject = "project"
+)
+
+// Kinds of pruned data, used in reports and metric labels.
+const (
+	KindFileVersions  = "file_versions"
+	KindRestorePoints = "restore_points"
+	KindBuilds        = "build_artifacts"
+)
+
+const (
+	deleteBatchSize   = 500
+	maxReportProjects = 100
+)
+
+var (
+	// ErrRunning is returned when a pruning run is already in progress.
+	ErrRunning = errors.New("a retention run is already in progress")
+	// ErrInvalidOverride is returned for overrides with an unknown scope or negative limits.
+	ErrInvalidOverride = errors.New("invalid retention override")
+	// ErrOverrideNotFound is returned when deleting an unknown override.
+	ErrOverrideNotFound = errors.New("retention override not found")
+)
+
+var (
+	prunedRowsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "apex",
+		Subsystem: "retention",
+		Name:      "pruned_rows_total",
+		Help:      "Rows removed or cleared by retention pruning, by kind",
+	}, []string{"kind"})
+	reclaimedBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "apex",
+		Subsystem: "retention",
+		Name:      "reclaimed_bytes_total",
+		Help:      "Approximate bytes reclaimed by retention pruning, by kind",
+	}, []string{"kind"})
+	lastRunTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "apex",
+		Subsystem: "retention",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time the last non-dry-run retention pass finished",
+	})
+)
+
+// Policy is how long history is kept. Zero keeps forever.
+type Policy struct {
+	VersionDays      int  `json:"version_days"`
+	VersionsPerFile  int  `json:"versions_per_file"`
+	RestorePointDays int  `json:"restore_point_days"`
+	BuildDays        int  `json:"build_days"` // build file/agent/state payloads; the build row itself is kept
+	Exempt           bool `json:"exempt,omitempty"`
+}
+
+// planPolicies are the defaults per subscription plan. Enterprise and owner
+// accounts keep everything.
+var planPolicies = map[string]Policy{
+	"free":    {VersionDays: 30, VersionsPerFile: 50, RestorePointDays: 14, BuildDays: 30},
+	"builder": {VersionDays: 90, VersionsPerFile: 200, RestorePointDays: 30, BuildDays: 90},
+	"pro":     {VersionDays: 180, VersionsPerFile: 500, RestorePointDays: 90, BuildDays: 180},
+	"team":    {VersionDays: 365, VersionsPerFile: 1000, RestorePointDays: 180, BuildDays: 365},
+}
+
+// PlanPolicy returns the default policy for a subscription plan. Unknown
+// plans get the free policy.
+func PlanPolicy(plan string) Policy {
+	switch plan {
+	case "enterprise", "owner":
+		return Policy{}
+	}
+	if policy, ok := planPolicies[plan]; ok {
+		return policy
+	}
+	return planPolicies["free"]
+}
+
+// PlanPolicies returns the defaults for every plan, for the admin API.
+func PlanPolicies() map[string]Policy {
+	out := make(map[string]Policy, len(planPolicies)+2)
+	for plan, policy := range planPolicies {
+		out[plan] = policy
+	}
+	out["enterprise"] = Policy{}
+	out["owner"] = Policy{}
+	return out
+}
+
+// Override replaces some of a plan's limits for one user or project. Nil
+// fields keep the plan default; a project override wins over its owner's.
+type Override struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Scope            string    `gorm:"not null;size:16;uniqueIndex:idx_retention_override_scope" json:"scope"`
+	ScopeID          uint      `gorm:"not null;uniqueIndex:idx_retention_override_scope" json:"scope_id"`
+	VersionDays      *int      `json:"version_days,omitempty"`
+	VersionsPerFile  *int      `json:"versions_per_file,omitempty"`
+	RestorePointDays *int      `json:"restore_point_days,omitempty"`
+	BuildDays        *int      `json:"build_days,omitempty"`
+	Exempt           bool      `json:"exempt"`
+	Note             string    `gorm:"size:500" json:"note,omitempty"`
+	SetByID          uint      `json:"set_by_id"`
+}
+
+func (Override) TableName() string { return "retention_overrides" }
+
+func (o *Override) apply(policy Policy) Policy {
+	if o == nil {
+		return policy
+	}
+	if o.VersionDays != nil {
+		policy.VersionDays = *o.VersionDays
+	}
+	if o.VersionsPerFile != nil {
+		policy.VersionsPerFile = *o.VersionsPerFile
+	}
+	if o.RestorePointDays != nil {
+		policy.RestorePointDays = *o.RestorePointDays
+	}
+	if o.BuildDays != nil {
+		policy.BuildDays = *o.BuildDays
+	}
+	policy.Exempt = policy.Exempt || o.Exempt
+	return policy
+}
+
+// Run records one pruning pass, or what a dry run would have pruned.
+type Run struct {
+	ID                  uint       `gorm:"primarykey" json:"id"`
+	StartedAt           time.Time  `gorm:"index" json:"started_at"`
+	FinishedAt          *time.Time `json:"finished_at,omitempty"`
+	DryRun              bool       `json:"dry_run"`
+	TriggeredByID       *uint      `json:"triggered_by_id,omitempty"` // nil for the background job
+	Projects            int        `json:"projects"`
+	VersionsPruned      int64      `json:"versions_pruned"`
+	VersionBytes        int64      `json:"version_bytes"`
+	RestorePointsPruned int64      `json:"restore_points_pruned"`
+	RestorePointBytes   int64      `json:"restore_point_bytes"`
+	BuildsPruned        int64      `json:"builds_pruned"`
+	BuildBytes          int64      `json:"build_bytes"`
+	Error               string     `gorm:"type:text" json:"error,omitempty"`
+}
+
+func (Run) TableName() string { return "retention_runs" }
+
+// reclaimedBytes is the total across kinds
+func (r *Run) reclaimedBytes() int64 {
+	return r.VersionBytes + r.RestorePointBytes + r.BuildBytes
+}
+
+// ProjectReport is what a run pruned, or would prune, in one project.
+type ProjectReport struct {
+	ProjectID           uint   `json:"project_id"`
+	OwnerID             uint   `json:"owner_id"`
+	Plan                string `json:"plan"`
+	Policy              Policy `json:"policy"`
+	VersionsPruned      int64  `json:"versions_pruned"`
+	VersionBytes        int64  `json:"version_bytes"`
+	RestorePointsPruned int64  `json:"restore_points_pruned"`
+	RestorePointBytes   int64  `json:"restore_point_bytes"`
+}
+
+// Report is a finished run with the projects it touched, largest first.
+type Report struct {
+	Run
+	ReclaimedBytes int64           `json:"reclaimed_bytes"`
+	ProjectReports []ProjectReport `json:"project_reports"`
+}
+
+// Service resolves retention policies and prunes history.
+type Service struct {
+	db  *gorm.DB
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewService creates a new retention Service backed by the given database.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, now: time.Now}
+}
+
+// AutoMigrate creates the override and run tables.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Override{}, &Run{})
+}
+
+// Overrides lists every admin override.
+func (s *Service) Overrides(ctx context.Context) ([]Override, error) {
+	var overrides []Override
+	if err := s.db.WithContext(ctx).Order("scope, scope_id").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("retention: list overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// SetOverride creates or replaces the override for o.Scope and o.ScopeID.
+func (s *Service) SetOverride(ctx context.Context, o Override) (*Override, error) {
+	if (o.Scope != ScopeUser && o.Scope != ScopeProject) || o.ScopeID == 0 {
+		return nil, ErrInvalidOverride
+	}
+	for _, limit := range []*int{o.VersionDays, o.VersionsPerFile, o.RestorePointDays, o.BuildDays} {
+		if limit != nil && *limit < 0 {
+			return nil, ErrInvalidOverride
+		}
+	}
+	db := s.db.WithContext(ctx)
+	var existing Override
+	err := db.Where("scope = ? AND scope_id = ?", o.Scope, o.ScopeID).First(&existing).Error
+	switch {
+	case err == nil:
+		o.ID, o.CreatedAt = existing.ID, existing.CreatedAt
+		err = db.Save(&o).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = db.Create(&o).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retention: save override: %w", err)
+	}
+	return &o, nil
+}
+
+// DeleteOverride removes an override, restoring the plan default.
+func (s *Service) DeleteOverride(ctx context.Context, id uint) error {
+	res := s.db.WithContext(ctx).Delete(&Override{}, id)
+	if res.Error != nil {
+		return fmt.Errorf("retention: delete override: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
+
+// Runs returns the most recent runs, newest first.
+func (s *Service) Runs(ctx context.Context, limit int) ([]Run, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var runs []Run
+	if err := s.db.WithContext(ctx).Order("started_at DESC, id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("retention: list runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Prune runs one retention pass. A dry run reports what would be pruned
+// without changing anything. Every pass is recorded in retention_runs.
+func (s *Service) Prune(ctx context.Context, dryRun bool, triggeredBy *uint) (*Report, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	report := &Report{Run: Run{StartedAt: now, DryRun: dryRun, TriggeredByID: triggeredBy}}
+	runErr := s.prune(ctx, now, report)
+
+	finished := s.now().UTC()
+	report.FinishedAt = &finished
+	report.ReclaimedBytes = report.reclaimedBytes()
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	if err := s.db.WithContext(ctx).Create(&report.Run).Error; err != nil {
+		log.Printf("retention: failed to record run: %v", err)
+	}
+	sort.Slice(report.ProjectReports, func(i, j int) bool {
+		a, b := report.ProjectReports[i], report.ProjectReports[j]
+		return a.VersionBytes+a.RestorePointBytes > b.VersionBytes+b.RestorePointBytes
+	})
+	if len(report.ProjectReports) > maxReportProjects {
+		report.ProjectReports = report.ProjectReports[:maxReportProjects]
+	}
+
+	if !dryRun {
+		prunedRowsTotal.WithLabelValues(KindFileVersions).Add(float64(report.VersionsPruned))
+		prunedRowsTotal.WithLabelValues(KindRestorePoints).Add(float64(report.RestorePointsPruned))
+		prunedRowsTotal.WithLabelValues(KindBuilds).Add(float64(report.BuildsPruned))
+		reclaimedBytesTotal.WithLabelValues(KindFileVersions).Add(float64(report.VersionBytes))
+		reclaimedBytesTotal.WithLabelValues(KindRestorePoints).Add(float64(report.RestorePointBytes))
+		reclaimedBytesTotal.WithLabelValues(KindBuilds).Add(float64(report.BuildBytes))
+		lastRunTimestamp.Set(float64(finished.Unix()))
+	}
+	return report, runErr
+}
+
+// Start runs Prune on a ticker until ctx is cancelled.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := s.Prune(ctx, false, nil)
+				if errors.Is(err, ErrRunning) {
+					continue
+				}
+				if err != nil {
+					log.Printf("retention: prune failed: %v", err)
+				} else if report.ReclaimedBytes > 0 {
+					log.Printf("retention: pruned %d versions, %d restore points and %d build payloads (%d bytes)",
+						report.VersionsPruned, report.RestorePointsPruned, report.BuildsPruned, report.ReclaimedBytes)
+				}
+			}
+		}
+	}()
+}
+
+// policies holds the overrides loaded for one run
+type policies struct {
+	users    map[uint]*Override
+	projects map[uint]*Override
+}
+
+func (s *Service) loadPolicies(ctx context.Context) (*policies, error) {
+	overrides, err := s.Overrides(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := &policies{users: map[uint]*Override{}, projects: map[uint]*Override{}}
+	for i := range overrides {
+		o := &overrides[i]
+		if o.Scope == ScopeProject {
+			p.projects[o.ScopeID] = o
+		} else {
+			p.users[o.ScopeID] = o
+		}
+	}
+	return p, nil
+}
+
+func (p *policies) forProject(plan string, ownerID, projectID uint) Policy {
+	return p.projects[projectID].apply(p.users[ownerID].apply(PlanPolicy(plan)))
+}
+
+func (p *policies) forUser(plan string, userID uint) Policy {
+	return p.users[userID].apply(PlanPolicy(plan))
+}
+
+type projectRow struct {
+	ID               uint
+	OwnerID          uint
+	SubscriptionType string
+}
+
+func (s *Service) prune(ctx context.Context, now time.Time, report *Report) error {
+	pols, err := s.loadPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	db := s.db.WithContext(ctx)
+
+	var projects []projectRow
+	if err := db.Table("projects").
+		Select("projects.id, projects.owner_id, users.subscription_type").
+		Joins("JOIN users ON users.id = projects.owner_id").
+		Where("projects.deleted_at IS NULL").
+		Order("projects.id").Scan(&projects).Error; err != nil {
+		return fmt.Errorf("retention: list projects: %w", err)
+	}
+	for _, project := range projects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		policy := pols.forProject(project.SubscriptionType, project.OwnerID, project.ID)
+		if policy.Exempt {
+			continue
+		}
+		pr := ProjectReport{ProjectID: project.ID, OwnerID: project.OwnerID, Plan: project.SubscriptionType, Policy: policy}
+		if err := s.pruneVersions(db, now, policy, report.DryRun, &pr); err != nil {
+			return err
+		}
+		if err := s.pruneRestorePoints(db, now, policy, report.DryRun, &pr); err != nil {
+			return err
+		}
+		if pr.VersionsPruned+pr.RestorePointsPruned == 0 {
+			continue
+		}
+		report.Projects++
+		report.VersionsPruned += pr.VersionsPruned
+		report.VersionBytes += pr.VersionBytes
+		report.RestorePointsPruned += pr.RestorePointsPruned
+		report.RestorePointBytes += pr.RestorePointBytes
+		report.ProjectReports = append(report.ProjectReports, pr)
+	}
+	if !report.DryRun && report.RestorePointsPruned > 0 {
+		if err := s.sweepBlobs(db); err != nil {
+			return err
+		}
+	}
+	return s.pruneBuilds(db, now, pols, report)
+}
+
+type versionRow struct {
+	ID          uint
+	FileID      uint
+	Size        int64
+	CreatedAt   time.Time
+	IsPinned    bool
+	IsProtected bool
+	DeletedAt   *time.Time
+}
+
+// pruneVersions removes a project's versions older than the policy window
+// or past the per-file count. Versions already deleted by users are purged
+// for good; the newest live version of each file is always kept.
+func (s *Service) pruneVersions(db *gorm.DB, now time.Time, policy Policy, dryRun bool, pr *ProjectReport) error {
+	if policy.VersionDays == 0 && policy.VersionsPerFile == 0 {
+		return nil
+	}
+	var rows []versionRow
+	if err := db.Unscoped().Model(&models.FileVersion{}).
+		Select("id, file_id, size, created_at, is_pinned, is_protected, deleted_at").
+		Where("project_id = ?", pr.ProjectID).
+		Order("file_id, version DESC").Scan(&rows).Error; err != nil {
+		return fmt.Errorf("retention: load versions of project %d: %w", pr.ProjectID, err)
+	}
+
+	cutoff := now.AddDate(0, 0, -policy.VersionDays)
+	var ids []uint
+	var fileID uint
+	rank := 0
+	for _, row := range rows {
+		if row.FileID != fileID {
+			fileID, rank = row.FileID, 0
+		}
+		prune := row.DeletedAt != nil
+		if !prune {
+			rank++
+			prune = rank > 1 && !row.IsPinned && !row.IsProtected &&
+				((policy.VersionDays > 0 && row.CreatedAt.Before(cutoff)) ||
+					(policy.VersionsPerFile > 0 && rank > policy.VersionsPerFile))
+		}
+		if prune {
+			ids = append(ids, row.ID)
+			pr.VersionBytes += row.Size
+		}
+	}
+	pr.VersionsPruned = int64(len(ids))
+	if dryRun {
+		return nil
+	}
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		end := min(start+deleteBatchSize, len(ids))
+		if err := db.Unscoped().Where("id IN ?", ids[start:end]).Delete(&models.FileVersion{}).Error; err != nil {
+			return fmt.Errorf("retention: delete versions of project %d: %w", pr.ProjectID, err)
+		}
+	}
+	return nil
+}
+
+// pruneRestorePoints removes unprotected restore points older than the
+// policy window
+func (s *Service) pruneRestorePoints(db *gorm.DB, now time.Time, policy Policy, dryRun bool, pr *ProjectReport) error {
+	if policy.RestorePointDays == 0 {
+		return nil
+	}
+	var points []restorepoints.Point
+	if err := db.Select("id", "total_size").
+		Where("project_id = ? AND created_at < ? AND (is_protected IS NULL OR is_protected = ?)",
+			pr.ProjectID, now.AddDate(0, 0, -policy.RestorePointDays), false).
+		Find(&points).Error; err != nil {
+		return fmt.Errorf("retention: load restore points of project %d: %w", pr.ProjectID, err)
+	}
+	ids := make([]uint, len(points))
+	for i, point := range points {
+		ids[i] = point.ID
+		pr.RestorePointBytes += point.TotalSize
+	}
+	pr.RestorePointsPruned = int64(len(ids))
+	if dryRun || len(ids) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("point_id IN ?", ids).Delete(&restorepoints.Entry{}).Error; err != nil {
+			return fmt.Errorf("retention: delete restore point files: %w", err)
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&restorepoints.Point{}).Error; err != nil {
+			return fmt.Errorf("retention: delete restore points: %w", err)
+		}
+		return nil
+	})
+}
+
+// sweepBlobs removes restore point content no remaining point refers to
+func (s *Service) sweepBlobs(db *gorm.DB) error {
+	err := db.Where("hash NOT IN (?)",
+		db.Model(&restorepoints.Entry{}).Distinct("content_hash").Where("content_hash <> ''")).
+		Delete(&restorepoints.Blob{}).Error
+	if err != nil {
+		return fmt.Errorf("retention: sweep restore point content: %w", err)
+	}
+	return nil
+}
+
+type buildOwnerRow struct {
+	ID               uint
+	SubscriptionType string
+}
+
+type buildRow struct {
+	ID    uint
+	Bytes int64
+}
+
+// buildPayloadColumns are cleared from completed builds past retention; the
+// row keeps its status, cost and timing for history and billing
+var buildPayloadColumns = []string{
+	"files_json", "agents_json", "tasks_json", "checkpoints_json",
+	"state_json", "activity_json", "interaction_json",
+}
+
+// pruneBuilds clears the JSON payloads of finished builds older than their
+// owner's build window
+func (s *Service) pruneBuilds(db *gorm.DB, now time.Time, pols *policies, report *Report) error {
+	var owners []buildOwnerRow
+	if err := db.Table("users").Select("users.id, users.subscription_type").
+		Where("users.id IN (?)", db.Model(&models.CompletedBuild{}).Distinct("user_id").Where("artifacts_pruned_at IS NULL")).
+		Scan(&owners).Error; err != nil {
+		return fmt.Errorf("retention: list build owners: %w", err)
+	}
+
+	sizeExpr := "0"
+	for _, col := range buildPayloadColumns {
+		sizeExpr += fmt.Sprintf(" + COALESCE(LENGTH(%s), 0)", col)
+	}
+	cleared := map[string]any{}
+	for _, col := range buildPayloadColumns {
+		cleared[col] = ""
+	}
+
+	for _, owner := range owners {
+		policy := pols.forUser(owner.SubscriptionType, owner.ID)
+		if policy.Exempt || policy.BuildDays == 0 {
+			continue
+		}
+		var rows []buildRow
+		if err := db.Model(&models.CompletedBuild{}).
+			Select("id, "+sizeExpr+" AS bytes").
+			Where("user_id = ? AND artifacts_pruned_at IS NULL AND status IN ? AND COALESCE(completed_at, updated_at) < ?",
+				owner.ID, []string{"completed", "failed", "cancelled"}, now.AddDate(0, 0, -policy.BuildDays)).
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("retention: load builds of user %d: %w", owner.ID, err)
+		}
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+			report.BuildBytes += row.Bytes
+		}
+		report.BuildsPruned += int64(len(ids))
+		if report.DryRun || len(ids) == 0 {
+			continue
+		}
+		cleared["artifacts_pruned_at"] = now
+		for start := 0; start < len(ids); start += deleteBatchSize {
+			end := min(start+deleteBatchSize, len(ids))
+			if err := db.Model(&models.CompletedBuild{}).Where("id IN ?", ids[start:end]).Updates(cleared).Error; err != nil {
+				return fmt.Errorf("retention: clear builds of user %d: %w", owner.ID, err)
+			}
+		}
+	}
+	return nil
+}
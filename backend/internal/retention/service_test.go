@@ -0,0 +1,159 @@
+package retention
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/restorepoints"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+var testNow = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}, &models.FileVersion{}, &models.CompletedBuild{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	if err := restorepoints.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate restore points: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate retention: %v", err)
+	}
+	service := NewService(db)
+	service.now = func() time.Time { return testNow }
+	return service, db
+}
+
+// seedProject creates a project for a user on plan with one file and n
+// versions, one per day ending today
+func seedProject(t *testing.T, db *gorm.DB, plan string, n int) (models.User, models.Project, models.File) {
+	t.Helper()
+	user := models.User{Username: plan + "-user", Email: plan + "@example.com", PasswordHash: "x", SubscriptionType: plan}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	project := models.Project{Name: plan, Language: "go", OwnerID: user.ID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	file := models.File{ProjectID: project.ID, Path: "main.go", Name: "main.go", Type: "file"}
+	if err := db.Create(&file).Error; err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		version := models.FileVersion{
+			FileID: file.ID, ProjectID: project.ID, Version: i, Size: 100,
+			AuthorID: user.ID, FilePath: file.Path, FileName: file.Name,
+			CreatedAt: testNow.AddDate(0, 0, i-n),
+		}
+		if err := db.Create(&version).Error; err != nil {
+			t.Fatalf("create version: %v", err)
+		}
+	}
+	return user, project, file
+}
+
+func TestPruneVersionsByAgeAndCount(t *testing.T) {
+	service, db := newTestService(t)
+	_, _, freeFile := seedProject(t, db, "free", 60)
+	seedProject(t, db, "enterprise", 60)
+
+	// Versions 1 and 2 are past both limits but pinned or protected
+	db.Model(&models.FileVersion{}).Where("file_id = ? AND version = 1", freeFile.ID).Update("is_pinned", true)
+	db.Model(&models.FileVersion{}).Where("file_id = ? AND version = 2", freeFile.ID).Updates(map[string]any{"tag": "v1", "is_protected": true})
+
+	dry, err := service.Prune(context.Background(), true, nil)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	// Free keeps 50 versions per file and 30 days: versions 3..29 go
+	if dry.VersionsPruned != 27 || dry.VersionBytes != 2700 || dry.Projects != 1 {
+		t.Fatalf("dry run report = %+v", dry.Run)
+	}
+	var count int64
+	db.Model(&models.FileVersion{}).Count(&count)
+	if count != 120 {
+		t.Fatalf("dry run deleted versions: %d left", count)
+	}
+
+	report, err := service.Prune(context.Background(), false, nil)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if report.VersionsPruned != 27 || report.ReclaimedBytes != 2700 {
+		t.Fatalf("report = %+v", report.Run)
+	}
+	db.Model(&models.FileVersion{}).Where("file_id = ?", freeFile.ID).Count(&count)
+	if count != 33 {
+		t.Fatalf("free file keeps %d versions, want 33", count)
+	}
+
+	runs, err := service.Runs(context.Background(), 0)
+	if err != nil || len(runs) != 2 || runs[0].DryRun {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+}
+
+func TestOverridesAndBuildPayloads(t *testing.T) {
+	service, db := newTestService(t)
+	user, project, _ := seedProject(t, db, "free", 40)
+
+	exempt := true
+	if _, err := service.SetOverride(context.Background(), Override{Scope: ScopeProject, ScopeID: project.ID, Exempt: exempt}); err != nil {
+		t.Fatalf("set override: %v", err)
+	}
+	keep := 365
+	if _, err := service.SetOverride(context.Background(), Override{Scope: "org", ScopeID: 1, BuildDays: &keep}); err != ErrInvalidOverride {
+		t.Fatalf("bad scope: err = %v", err)
+	}
+
+	old := testNow.AddDate(0, 0, -45)
+	recent := testNow.AddDate(0, 0, -5)
+	for i, at := range []time.Time{old, recent} {
+		build := models.CompletedBuild{
+			BuildID: string(rune('a' + i)), UserID: user.ID, Status: "completed",
+			FilesJSON: strings.Repeat("x", 1000), StateJSON: "{}", CompletedAt: &at,
+		}
+		if err := db.Create(&build).Error; err != nil {
+			t.Fatalf("create build: %v", err)
+		}
+	}
+
+	report, err := service.Prune(context.Background(), false, nil)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if report.VersionsPruned != 0 {
+		t.Fatalf("exempt project lost %d versions", report.VersionsPruned)
+	}
+	if report.BuildsPruned != 1 || report.BuildBytes != 1002 {
+		t.Fatalf("builds pruned = %d (%d bytes)", report.BuildsPruned, report.BuildBytes)
+	}
+	var builds []models.CompletedBuild
+	db.Order("build_id").Find(&builds)
+	if builds[0].FilesJSON != "" || builds[0].ArtifactsPrunedAt == nil {
+		t.Fatalf("old build payload kept: %+v", builds[0])
+	}
+	if builds[1].FilesJSON == "" || builds[1].ArtifactsPrunedAt != nil {
+		t.Fatal("recent build payload pruned")
+	}
+
+	// A user override extends the build window
+	if _, err := service.SetOverride(context.Background(), Override{Scope: ScopeUser, ScopeID: user.ID, BuildDays: &keep}); err != nil {
+		t.Fatalf("set user override: %v", err)
+	}
+	if policy := (&policies{users: map[uint]*Override{user.ID: {BuildDays: &keep}}}).forUser("free", user.ID); policy.BuildDays != 365 || policy.VersionDays != 30 {
+		t.Fatalf("user policy = %+v", policy)
+	}
+}
@@ -0,0 +1,374 @@
+// Package runartifacts captures the files a project execution creates or
+// modifies in its sandbox workspace, which would otherwise vanish with the
+// workspace. Capture is opt-in per run and size limited. Captured files are
+// returned with the execution result and kept for a day, so the user can
+// confirm which of them to save back into the project. Binary files are kept
+// in the blob store, like binary project files.
+package runartifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxFiles caps the files captured from one run.
+	MaxFiles = 50
+	// MaxFileBytes caps a single captured file.
+	MaxFileBytes = 512 * 1024
+	// MaxTotalBytes caps the content captured from one run.
+	MaxTotalBytes = 4 * 1024 * 1024
+	// Retention is how long captured files wait to be applied.
+	Retention = 24 * time.Hour
+)
+
+// Artifact statuses.
+const (
+	StatusCreated  = "created"
+	StatusModified = "modified"
+)
+
+// Reasons a changed file was listed without its content. SkipBinary is only
+// used when no blob store is configured.
+const (
+	SkipBinary     = "binary"
+	SkipTooLarge   = "too_large"
+	SkipLimit      = "limit_reached"
+	SkipUnreadable = "unreadable"
+)
+
+// ErrNotFound is returned when an execution has no artifacts the caller can
+// see, including ones that have expired.
+var ErrNotFound = errors.New("no artifacts found for this execution")
+
+// ErrStorageLimit is returned by Apply when the caller's storage check
+// refuses the write.
+var ErrStorageLimit = errors.New("storage limit reached")
+
+// skippedDirs hold dependencies and caches rather than run output.
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "__pycache__": true, ".venv": true, "venv": true,
+	".cache": true, ".npm": true, ".next": true, "target": true, ".pytest_cache": true,
+}
+
+// Artifact is a file a run created or modified.
+type Artifact struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	ExecutionID string `json:"execution_id" gorm:"index;not null"`
+	ProjectID   uint   `json:"project_id" gorm:"index"`
+	UserID      uint   `json:"-" gorm:"index"`
+	Path        string `json:"path" gorm:"not null"`
+	Status      string `json:"status"`
+	Size        int64  `json:"size"`
+	// Content is empty when SkipReason is set or the file is binary.
+	Content string `json:"content,omitempty" gorm:"type:text"`
+	// Hash is the SHA-256 of the captured bytes. A binary artifact's bytes
+	// live in the blob store under it (see storage.BlobKey).
+	Hash       string     `json:"hash,omitempty"`
+	IsBinary   bool       `json:"is_binary,omitempty"`
+	SkipReason string     `json:"skip_reason,omitempty"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// data holds a binary artifact's bytes between Capture and Save.
+	data []byte
+}
+
+// TableName keeps artifacts next to the executions they came from.
+func (Artifact) TableName() string { return "execution_artifacts" }
+
+// Capture lists the regular files under dir that are new or differ from
+// before, which maps workspace-relative paths to their content when the run
+// started. Symlinks and dependency directories are ignored.
+func Capture(dir string, before map[string]string) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Runs can leave behind paths the platform can't read
+			if p == dir {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != dir && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		status := StatusCreated
+		if original, ok := before[rel]; ok {
+			if int64(len(original)) == info.Size() {
+				if data, err := os.ReadFile(p); err == nil && string(data) == original {
+					return nil
+				}
+			}
+			status = StatusModified
+		}
+		artifacts = append(artifacts, Artifact{Path: rel, Status: status, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path < artifacts[j].Path })
+	total := int64(0)
+	for i := range artifacts {
+		a := &artifacts[i]
+		switch {
+		case i >= MaxFiles:
+			a.SkipReason = SkipLimit
+			continue
+		case a.Size > MaxFileBytes:
+			a.SkipReason = SkipTooLarge
+			continue
+		case total+a.Size > MaxTotalBytes:
+			a.SkipReason = SkipLimit
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(a.Path)))
+		if err != nil {
+			a.SkipReason = SkipUnreadable
+			continue
+		}
+		a.Hash = contentHash(data)
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			a.IsBinary = true
+			a.data = data
+		} else {
+			a.Content = string(data)
+		}
+		total += a.Size
+	}
+	return artifacts, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Service stores captured artifacts until they are applied or expire.
+type Service struct {
+	db    *gorm.DB
+	blobs storage.Provider
+}
+
+// NewService creates an artifact service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// SetBlobStore keeps binary artifacts in the blob store. Without one they
+// are listed with SkipBinary.
+func (s *Service) SetBlobStore(p storage.Provider) {
+	s.blobs = p
+}
+
+// AutoMigrate creates the artifacts table.
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&Artifact{})
+}
+
+// Save stores a run's artifacts, dropping expired ones from earlier runs.
+// Binary content is uploaded to the blob store first.
+func (s *Service) Save(executionID string, projectID, userID uint, artifacts []Artifact) error {
+	for i := range artifacts {
+		a := &artifacts[i]
+		if !a.IsBinary || a.SkipReason != "" {
+			continue
+		}
+		if s.blobs == nil {
+			a.SkipReason = SkipBinary
+		} else if _, _, err := storage.PutBlob(context.Background(), s.blobs, bytes.NewReader(a.data), MaxFileBytes, mimeType(a.Path)); err != nil {
+			log.Printf("Execution %s: failed to store binary artifact %s: %v", executionID, a.Path, err)
+			a.SkipReason = SkipUnreadable
+		}
+		a.data = nil
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("created_at < ?", time.Now().Add(-Retention)).Delete(&Artifact{}).Error; err != nil {
+			return err
+		}
+		for i := range artifacts {
+			artifacts[i].ExecutionID = executionID
+			artifacts[i].ProjectID = projectID
+			artifacts[i].UserID = userID
+		}
+		if len(artifacts) == 0 {
+			return nil
+		}
+		return tx.Create(&artifacts).Error
+	})
+}
+
+// List returns the unexpired artifacts of the caller's execution.
+func (s *Service) List(executionID string, userID uint) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := s.db.Where("execution_id = ? AND user_id = ? AND created_at >= ?", executionID, userID, time.Now().Add(-Retention)).
+		Order("path ASC").
+		Find(&artifacts).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(artifacts) == 0 {
+		return nil, ErrNotFound
+	}
+	return artifacts, nil
+}
+
+// ApplyResult reports which artifacts were saved into the project.
+type ApplyResult struct {
+	Applied []string `json:"applied"`
+	// Skipped maps paths that were not saved to the reason.
+	Skipped map[string]string `json:"skipped,omitempty"`
+	// StorageDelta is how much the project's stored bytes grew.
+	StorageDelta int64 `json:"-"`
+}
+
+// Apply saves the named artifacts, or all of them when paths is empty, into
+// the execution's project, overwriting files at the same path. Artifacts
+// captured without content and unknown paths are skipped. allow, when set,
+// is asked whether the project may grow by the bytes the write adds; when
+// it refuses, nothing is saved and Apply returns ErrStorageLimit.
+func (s *Service) Apply(executionID string, userID uint, paths []string, allow func(delta int64) bool) (*ApplyResult, error) {
+	artifacts, err := s.List(executionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[strings.TrimPrefix(p, "/")] = true
+	}
+
+	applyAll := len(wanted) == 0
+	result := &ApplyResult{Applied: []string{}, Skipped: map[string]string{}}
+	var pending []Artifact
+	existing := make(map[string]*models.File)
+	for _, a := range artifacts {
+		if !applyAll && !wanted[a.Path] {
+			continue
+		}
+		delete(wanted, a.Path)
+		if a.SkipReason != "" {
+			result.Skipped[a.Path] = a.SkipReason
+			continue
+		}
+		var file models.File
+		err := s.db.Where("project_id = ? AND path IN ?", a.ProjectID, []string{a.Path, "/" + a.Path}).First(&file).Error
+		switch {
+		case err == nil:
+			existing[a.Path] = &file
+			result.StorageDelta += a.Size - file.Size
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			result.StorageDelta += a.Size
+		default:
+			return nil, err
+		}
+		pending = append(pending, a)
+	}
+	if allow != nil && len(pending) > 0 && !allow(result.StorageDelta) {
+		return nil, ErrStorageLimit
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, a := range pending {
+			if err := saveProjectFile(tx, a, userID, existing[a.Path]); err != nil {
+				return err
+			}
+			if err := tx.Model(&Artifact{}).Where("id = ?", a.ID).Update("applied_at", now).Error; err != nil {
+				return err
+			}
+			result.Applied = append(result.Applied, a.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range wanted {
+		result.Skipped[p] = "not_captured"
+	}
+	return result, nil
+}
+
+// saveProjectFile writes a into its project, replacing current when the
+// path already exists.
+func saveProjectFile(tx *gorm.DB, a Artifact, userID uint, current *models.File) error {
+	hash := a.Hash
+	if !a.IsBinary {
+		// Artifacts captured before hashes were recorded
+		hash = contentHash([]byte(a.Content))
+	}
+	if current == nil {
+		file := models.File{
+			ProjectID:  a.ProjectID,
+			Path:       a.Path,
+			Name:       path.Base(a.Path),
+			Type:       "file",
+			MimeType:   "text/plain",
+			Content:    a.Content,
+			Size:       a.Size,
+			Hash:       hash,
+			IsBinary:   a.IsBinary,
+			LastEditBy: userID,
+		}
+		if a.IsBinary {
+			file.MimeType = mimeType(a.Path)
+		}
+		return tx.Create(&file).Error
+	}
+	updates := map[string]interface{}{
+		"content":      a.Content,
+		"size":         a.Size,
+		"hash":         hash,
+		"is_binary":    a.IsBinary,
+		"version":      current.Version + 1,
+		"last_edit_by": userID,
+	}
+	if a.IsBinary {
+		updates["mime_type"] = mimeType(a.Path)
+	}
+	return tx.Model(current).Updates(updates).Error
+}
+
+// mimeType guesses a binary artifact's type from its extension.
+func mimeType(filePath string) string {
+	if t := mime.TypeByExtension(strings.ToLower(path.Ext(filePath))); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,226 @@
+package runartifacts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/internal/storage"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func writeWorkspace(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCaptureListsNewAndModifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	before := map[string]string{"main.py": "print('hi')", "data/config.json": "{}"}
+	writeWorkspace(t, dir, before)
+
+	// What the run wrote
+	writeWorkspace(t, dir, map[string]string{
+		"data/config.json":          `{"generated":true}`,
+		"out/report.md":             "# Report",
+		"out/chart.png":             "\x89PNG\x00\x01",
+		"out/huge.log":              strings.Repeat("x", MaxFileBytes+1),
+		"node_modules/pkg/index.js": "module.exports = 1",
+	})
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "out", "passwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts, err := Capture(dir, before)
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	got := map[string]Artifact{}
+	for _, a := range artifacts {
+		got[a.Path] = a
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 artifacts, got %+v", artifacts)
+	}
+	if a := got["data/config.json"]; a.Status != StatusModified || a.Content != `{"generated":true}` {
+		t.Fatalf("modified artifact = %+v", a)
+	}
+	if a := got["out/report.md"]; a.Status != StatusCreated || a.Content != "# Report" || a.SkipReason != "" {
+		t.Fatalf("created artifact = %+v", a)
+	}
+	if a := got["out/chart.png"]; !a.IsBinary || a.SkipReason != "" || a.Content != "" || string(a.data) != "\x89PNG\x00\x01" {
+		t.Fatalf("binary artifact = %+v", a)
+	}
+	if a := got["out/report.md"]; a.Hash != contentHash([]byte("# Report")) {
+		t.Fatalf("artifact hash = %q", a.Hash)
+	}
+	if a := got["out/huge.log"]; a.SkipReason != SkipTooLarge || a.Size != MaxFileBytes+1 {
+		t.Fatalf("large artifact = %+v", a)
+	}
+}
+
+func TestCaptureStopsAtFileLimit(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{}
+	for i := 0; i < MaxFiles+3; i++ {
+		files[filepath.Join("gen", strings.Repeat("a", i+1)+".txt")] = "x"
+	}
+	writeWorkspace(t, dir, files)
+
+	artifacts, err := Capture(dir, nil)
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	limited := 0
+	for _, a := range artifacts {
+		if a.SkipReason == SkipLimit {
+			limited++
+		}
+	}
+	if len(artifacts) != MaxFiles+3 || limited != 3 {
+		t.Fatalf("expected 3 of %d artifacts over the limit, got %d", len(artifacts), limited)
+	}
+}
+
+func TestApplySavesChosenArtifactsIntoProject(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	service := NewService(db)
+	if err := service.AutoMigrate(); err != nil {
+		t.Fatalf("migrate artifacts: %v", err)
+	}
+	db.Create(&models.File{ProjectID: 3, Path: "/data.json", Name: "data.json", Type: "file", Content: "{}", Version: 2})
+
+	err = service.Save("exec-1", 3, 9, []Artifact{
+		{Path: "data.json", Status: StatusModified, Size: 10, Content: `{"a":true}`},
+		{Path: "out/report.md", Status: StatusCreated, Size: 8, Content: "# Report"},
+		{Path: "out/chart.png", Status: StatusCreated, Size: 6, SkipReason: SkipBinary},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := service.List("exec-1", 10); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another user's list to be empty, got %v", err)
+	}
+
+	result, err := service.Apply("exec-1", 9, []string{"/data.json"}, nil)
+	if err != nil || len(result.Applied) != 1 || len(result.Skipped) != 0 {
+		t.Fatalf("apply one = %+v, err = %v", result, err)
+	}
+	result, err = service.Apply("exec-1", 9, []string{"data.json", "out/chart.png", "missing.txt"}, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(result.Applied) != 1 || result.Applied[0] != "data.json" {
+		t.Fatalf("applied = %v", result.Applied)
+	}
+	if result.Skipped["out/chart.png"] != SkipBinary || result.Skipped["missing.txt"] != "not_captured" {
+		t.Fatalf("skipped = %v", result.Skipped)
+	}
+	var updated models.File
+	db.Where("project_id = ? AND path = ?", 3, "/data.json").First(&updated)
+	if updated.Content != `{"a":true}` || updated.Version != 4 || updated.LastEditBy != 9 || updated.Hash != contentHash([]byte(`{"a":true}`)) {
+		t.Fatalf("updated file = %+v", updated)
+	}
+
+	// The storage check sees the bytes the write adds and can refuse it
+	var asked int64
+	if _, err := service.Apply("exec-1", 9, nil, func(delta int64) bool { asked = delta; return false }); !errors.Is(err, ErrStorageLimit) {
+		t.Fatalf("expected the storage check to refuse, got %v", err)
+	}
+	if asked != 8 {
+		t.Fatalf("storage delta = %d, want 8", asked)
+	}
+
+	// No paths applies everything that has content
+	result, err = service.Apply("exec-1", 9, nil, func(int64) bool { return true })
+	if err != nil || len(result.Applied) != 2 {
+		t.Fatalf("apply all = %+v, err = %v", result, err)
+	}
+	var created models.File
+	if err := db.Where("project_id = ? AND path = ?", 3, "out/report.md").First(&created).Error; err != nil || created.Name != "report.md" {
+		t.Fatalf("created file = %+v, err = %v", created, err)
+	}
+
+	// Expired artifacts are gone
+	db.Model(&Artifact{}).Where("execution_id = ?", "exec-1").Update("created_at", time.Now().Add(-Retention-time.Minute))
+	if _, err := service.Apply("exec-1", 9, nil, nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected expired artifacts to be unavailable, got %v", err)
+	}
+}
+
+func TestBinaryArtifactsGoThroughTheBlobStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	blobs, err := storage.NewLocalProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	service := NewService(db)
+	service.SetBlobStore(blobs)
+	if err := service.AutoMigrate(); err != nil {
+		t.Fatalf("migrate artifacts: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeWorkspace(t, dir, map[string]string{"out/chart.png": "\x89PNG\x00\x01"})
+	artifacts, err := Capture(dir, nil)
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if err := service.Save("exec-1", 3, 9, artifacts); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if artifacts[0].SkipReason != "" || artifacts[0].Hash == "" {
+		t.Fatalf("binary artifact = %+v", artifacts[0])
+	}
+	data, err := storage.ReadBlob(context.Background(), blobs, artifacts[0].Hash)
+	if err != nil || string(data) != "\x89PNG\x00\x01" {
+		t.Fatalf("blob = %q, err = %v", data, err)
+	}
+
+	result, err := service.Apply("exec-1", 9, nil, nil)
+	if err != nil || len(result.Applied) != 1 || result.StorageDelta != 6 {
+		t.Fatalf("apply = %+v, err = %v", result, err)
+	}
+	var file models.File
+	db.Where("project_id = ? AND path = ?", 3, "out/chart.png").First(&file)
+	if !file.IsBinary || file.Content != "" || file.Hash != artifacts[0].Hash || file.MimeType != "image/png" || file.Size != 6 {
+		t.Fatalf("binary file = %+v", file)
+	}
+
+	// Without a blob store binaries are listed but not kept
+	service.SetBlobStore(nil)
+	artifacts, _ = Capture(dir, nil)
+	if err := service.Save("exec-2", 3, 9, artifacts); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if artifacts[0].SkipReason != SkipBinary {
+		t.Fatalf("expected the binary to be skipped, got %+v", artifacts[0])
+	}
+}
@@ -0,0 +1,671 @@
+// Package schedules runs commands in a project's environment on a cron
+// schedule (scheduled deployments). Each run reserves execution minutes,
+// runs in the container sandbox, and keeps its logs in the run history;
+// failing tasks notify their owner and are disabled after repeated failures.
+package schedules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/internal/execution"
+	"apex-build/internal/notifications"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTickInterval is how often the scheduler looks for due tasks.
+const DefaultTickInterval = 30 * time.Second
+
+const (
+	// DefaultTimeout bounds a run when the task does not set one.
+	DefaultTimeout = 5 * time.Minute
+	// MaxTimeout is the longest a scheduled run may take.
+	MaxTimeout = 15 * time.Minute
+	// MaxTasksPerProject caps schedules per project.
+	MaxTasksPerProject = 20
+	// RunHistoryLimit is how many runs are kept per task.
+	RunHistoryLimit = 100
+	// AutoDisableAfter disables a task after this many consecutive failures.
+	AutoDisableAfter = 10
+	// maxLogBytes caps stored stdout and stderr per run.
+	maxLogBytes = 64 * 1024
+	// maxConcurrentRuns bounds scheduled runs across the instance.
+	maxConcurrentRuns = 8
+)
+
+// Run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run statuses beyond the sandbox's completed/failed/timeout/killed.
+const (
+	RunRunning       = "running"
+	RunSkipped       = "skipped"
+	RunQuotaExceeded = "quota_exceeded"
+	RunError         = "error"
+)
+
+var (
+	// ErrNotFound is returned for tasks or runs the user cannot see.
+	ErrNotFound = errors.New("scheduled task not found")
+	// ErrInvalid wraps validation failures.
+	ErrInvalid = errors.New("invalid scheduled task")
+	// ErrAlreadyRunning is returned when a manual run overlaps a running one.
+	ErrAlreadyRunning = errors.New("scheduled task is already running")
+)
+
+// Task is a command that runs in a project on a cron schedule.
+type Task struct {
+	ID                  uint       `gorm:"primarykey" json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	ProjectID           uint       `gorm:"not null;index" json:"project_id"`
+	UserID              uint       `gorm:"not null;index" json:"user_id"`
+	Name                string     `gorm:"not null;size:120" json:"name"`
+	Command             string     `gorm:"type:text;not null" json:"command"`
+	CronExpr            string     `gorm:"column:cron_expr;not null;size:120" json:"cron"`
+	Timezone            string     `gorm:"not null;size:64;default:UTC" json:"timezone"`
+	TimeoutSeconds      int        `gorm:"not null;default:300" json:"timeout_seconds"`
+	Enabled             bool       `gorm:"not null;index" json:"enabled"`
+	NextRunAt           *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	LastStatus          string     `gorm:"size:20" json:"last_status,omitempty"`
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutive_failures"`
+}
+
+// TableName keeps the table name explicit.
+func (Task) TableName() string { return "scheduled_tasks" }
+
+// Run is one execution of a task, with its logs.
+type Run struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	TaskID      uint       `gorm:"not null;index" json:"task_id"`
+	ProjectID   uint       `gorm:"not null" json:"project_id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Trigger     string     `gorm:"not null;size:16" json:"trigger"`
+	Status      string     `gorm:"not null;size:20" json:"status"`
+	ExecutionID string     `gorm:"size:64" json:"execution_id,omitempty"`
+	Command     string     `gorm:"type:text" json:"command"`
+	ExitCode    int        `json:"exit_code"`
+	Output      string     `gorm:"type:text" json:"output"`
+	ErrorOutput string     `gorm:"type:text" json:"error_output"`
+	DurationMs  int64      `json:"duration_ms"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName keeps the table name explicit.
+func (Run) TableName() string { return "scheduled_task_runs" }
+
+// Succeeded reports whether the run completed with exit code 0.
+func (r *Run) Succeeded() bool {
+	return r.Status == "completed" && r.ExitCode == 0
+}
+
+// Runner executes a command in a project's workspace. Implemented by
+// *handlers.ExecutionHandler.
+type Runner interface {
+	RunProjectCommand(ctx context.Context, projectID uint, command string, timeout time.Duration) (*execution.ExecutionResult, error)
+}
+
+// Quota reserves and settles execution minutes. Implemented by *usage.Tracker.
+type Quota interface {
+	ReserveExecution(ctx context.Context, userID uint, plan usage.PlanType, timeout time.Duration, enforce bool) (*usage.ExecutionReservation, error)
+	SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*usage.ExecutionReservation, error)
+	ReleaseExecution(ctx context.Context, reservationID string) error
+}
+
+// Notifier posts failure notifications. Implemented by *notifications.Service.
+type Notifier interface {
+	Notify(ctx context.Context, n *notifications.Notification) error
+}
+
+// TaskInput creates or patches a task; nil fields are left unchanged.
+type TaskInput struct {
+	Name           *string `json:"name"`
+	Command        *string `json:"command"`
+	Cron           *string `json:"cron"`
+	Timezone       *string `json:"timezone"`
+	TimeoutSeconds *int    `json:"timeout_seconds"`
+	Enabled        *bool   `json:"enabled"`
+}
+
+// Service manages scheduled tasks and runs them when due.
+type Service struct {
+	db       *gorm.DB
+	runner   Runner
+	quota    Quota
+	notifier Notifier
+
+	sem     chan struct{}
+	mu      sync.Mutex
+	running map[uint]bool
+	wg      sync.WaitGroup
+}
+
+// NewService creates a scheduled task Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:      db,
+		sem:     make(chan struct{}, maxConcurrentRuns),
+		running: make(map[uint]bool),
+	}
+}
+
+// SetRunner wires the sandbox that executes runs.
+func (s *Service) SetRunner(r Runner) { s.runner = r }
+
+// SetQuota wires execution-minute reservations.
+func (s *Service) SetQuota(q Quota) { s.quota = q }
+
+// SetNotifier wires failure notifications.
+func (s *Service) SetNotifier(n Notifier) { s.notifier = n }
+
+// AutoMigrate creates the scheduled task tables.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Task{}, &Run{})
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// apply validates input onto task and recomputes its next run.
+func (t *Task) apply(in TaskInput, now time.Time) error {
+	if in.Name != nil {
+		t.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Command != nil {
+		t.Command = strings.TrimSpace(*in.Command)
+	}
+	if in.Cron != nil {
+		t.CronExpr = strings.TrimSpace(*in.Cron)
+	}
+	if in.Timezone != nil {
+		t.Timezone = strings.TrimSpace(*in.Timezone)
+	}
+	if in.TimeoutSeconds != nil {
+		t.TimeoutSeconds = *in.TimeoutSeconds
+	}
+	if in.Enabled != nil {
+		t.Enabled = *in.Enabled
+	}
+
+	if t.Name == "" || len(t.Name) > 120 {
+		return invalid("name is required (max 120 characters)")
+	}
+	if t.Command == "" {
+		return invalid("command is required")
+	}
+	if t.Timezone == "" {
+		t.Timezone = "UTC"
+	}
+	if t.TimeoutSeconds == 0 {
+		t.TimeoutSeconds = int(DefaultTimeout / time.Second)
+	}
+	if t.TimeoutSeconds < 1 || time.Duration(t.TimeoutSeconds)*time.Second > MaxTimeout {
+		return invalid("timeout_seconds must be between 1 and %d", int(MaxTimeout/time.Second))
+	}
+	next, err := nextRun(t.CronExpr, t.Timezone, now)
+	if err != nil {
+		return err
+	}
+	if t.Enabled {
+		t.NextRunAt = &next
+	} else {
+		t.NextRunAt = nil
+	}
+	return nil
+}
+
+// nextRun returns the next UTC fire time after now for expr in timezone.
+func nextRun(expr, timezone string, now time.Time) (time.Time, error) {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return time.Time{}, invalid("cron: %v", err)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, invalid("unknown timezone %q", timezone)
+	}
+	next := schedule.Next(now.In(loc))
+	if next.IsZero() {
+		return time.Time{}, invalid("cron expression %q never fires", expr)
+	}
+	return next.UTC(), nil
+}
+
+// Create adds a task to a project the user owns.
+func (s *Service) Create(ctx context.Context, userID, projectID uint, in TaskInput) (*Task, error) {
+	var project models.Project
+	if err := s.db.WithContext(ctx).Select("id").Where("id = ? AND owner_id = ?", projectID, userID).First(&project).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	var count int64
+	s.db.WithContext(ctx).Model(&Task{}).Where("project_id = ?", projectID).Count(&count)
+	if count >= MaxTasksPerProject {
+		return nil, invalid("a project can have at most %d scheduled tasks", MaxTasksPerProject)
+	}
+
+	task := &Task{ProjectID: projectID, UserID: userID, Enabled: true}
+	if in.Cron == nil {
+		return nil, invalid("cron is required")
+	}
+	if err := task.apply(in, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(task).Error; err != nil {
+		return nil, fmt.Errorf("schedules: create failed: %w", err)
+	}
+	return task, nil
+}
+
+// Get returns a task owned by the user.
+func (s *Service) Get(ctx context.Context, userID, taskID uint) (*Task, error) {
+	var task Task
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	return &task, nil
+}
+
+// List returns a project's tasks.
+func (s *Service) List(ctx context.Context, userID, projectID uint) ([]Task, error) {
+	tasks := []Task{}
+	err := s.db.WithContext(ctx).Where("project_id = ? AND user_id = ?", projectID, userID).Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// Update patches a task. Re-enabling resets the failure streak.
+func (s *Service) Update(ctx context.Context, userID, taskID uint, in TaskInput) (*Task, error) {
+	task, err := s.Get(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	wasEnabled := task.Enabled
+	if err := task.apply(in, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if task.Enabled && !wasEnabled {
+		task.ConsecutiveFailures = 0
+	}
+	if err := s.db.WithContext(ctx).Save(task).Error; err != nil {
+		return nil, fmt.Errorf("schedules: update failed: %w", err)
+	}
+	return task, nil
+}
+
+// Delete removes a task and its run history.
+func (s *Service) Delete(ctx context.Context, userID, taskID uint) error {
+	task, err := s.Get(ctx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", task.ID).Delete(&Run{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(task).Error
+	})
+}
+
+// ListRuns returns a task's runs, newest first. Logs are omitted; fetch a
+// single run for its output.
+func (s *Service) ListRuns(ctx context.Context, userID, taskID uint, limit int) ([]Run, error) {
+	if _, err := s.Get(ctx, userID, taskID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > RunHistoryLimit {
+		limit = 50
+	}
+	runs := []Run{}
+	err := s.db.WithContext(ctx).
+		Omit("output", "error_output").
+		Where("task_id = ?", taskID).
+		Order("id DESC").Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// GetRun returns one run with its logs.
+func (s *Service) GetRun(ctx context.Context, userID, taskID, runID uint) (*Run, error) {
+	var run Run
+	if err := s.db.WithContext(ctx).Where("id = ? AND task_id = ? AND user_id = ?", runID, taskID, userID).First(&run).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	return &run, nil
+}
+
+// RunNow starts a task immediately, outside its schedule. The returned run
+// is in the running state; poll GetRun for the result.
+func (s *Service) RunNow(ctx context.Context, userID, taskID uint) (*Run, error) {
+	task, err := s.Get(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.claimRunning(task.ID) {
+		return nil, ErrAlreadyRunning
+	}
+	run, err := s.startRun(ctx, task, TriggerManual)
+	if err != nil {
+		s.releaseRunning(task.ID)
+		return nil, err
+	}
+	s.dispatch(*task, run)
+	return run, nil
+}
+
+// Start runs the scheduler until ctx is cancelled.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTickInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if started, err := s.RunDue(ctx, time.Now().UTC()); err != nil {
+					log.Printf("schedules: tick failed: %v", err)
+				} else if started > 0 {
+					log.Printf("schedules: started %d scheduled runs", started)
+				}
+			}
+		}
+	}()
+}
+
+// RunDue starts every enabled task whose next run is at or before now. Each
+// task is claimed by advancing next_run_at with a compare-and-set, so only
+// one instance runs it. A task still running from its previous fire records
+// a skipped run instead of overlapping.
+func (s *Service) RunDue(ctx context.Context, now time.Time) (int, error) {
+	var due []Task
+	if err := s.db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at").Limit(100).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	started := 0
+	for i := range due {
+		task := due[i]
+		next, err := nextRun(task.CronExpr, task.Timezone, now)
+		var nextAt *time.Time
+		if err == nil {
+			nextAt = &next
+		}
+		claim := s.db.WithContext(ctx).Model(&Task{}).
+			Where("id = ? AND next_run_at = ?", task.ID, *task.NextRunAt).
+			Update("next_run_at", nextAt)
+		if claim.Error != nil || claim.RowsAffected != 1 {
+			continue
+		}
+		task.NextRunAt = nextAt
+
+		if !s.claimRunning(task.ID) {
+			s.recordSkipped(ctx, &task, now)
+			continue
+		}
+		run, err := s.startRun(ctx, &task, TriggerSchedule)
+		if err != nil {
+			s.releaseRunning(task.ID)
+			log.Printf("schedules: failed to start task %d: %v", task.ID, err)
+			continue
+		}
+		s.dispatch(task, run)
+		started++
+	}
+	return started, nil
+}
+
+// Wait blocks until dispatched runs finish; used by tests and shutdown.
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Service) claimRunning(taskID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[taskID] {
+		return false
+	}
+	s.running[taskID] = true
+	return true
+}
+
+func (s *Service) releaseRunning(taskID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, taskID)
+}
+
+func (s *Service) startRun(ctx context.Context, task *Task, trigger string) (*Run, error) {
+	run := &Run{
+		TaskID:    task.ID,
+		ProjectID: task.ProjectID,
+		UserID:    task.UserID,
+		Trigger:   trigger,
+		Status:    RunRunning,
+		Command:   task.Command,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (s *Service) recordSkipped(ctx context.Context, task *Task, now time.Time) {
+	run := &Run{
+		TaskID:      task.ID,
+		ProjectID:   task.ProjectID,
+		UserID:      task.UserID,
+		Trigger:     TriggerSchedule,
+		Status:      RunSkipped,
+		Command:     task.Command,
+		ErrorOutput: "Skipped: the previous run is still in progress",
+		StartedAt:   now,
+		CompletedAt: &now,
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		log.Printf("schedules: failed to record skipped run for task %d: %v", task.ID, err)
+	}
+}
+
+func (s *Service) dispatch(task Task, run *Run) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.releaseRunning(task.ID)
+		s.sem <- struct{}{}
+		defer func() { <-s.sem }()
+		s.execute(context.Background(), &task, run)
+	}()
+}
+
+// execute runs the task in the sandbox and records the outcome.
+func (s *Service) execute(ctx context.Context, task *Task, run *Run) {
+	timeout := time.Duration(task.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	reservationID := ""
+	if s.quota != nil {
+		plan, enforce := BillingFor(ctx, s.db, task.UserID)
+		reservation, err := s.quota.ReserveExecution(ctx, task.UserID, plan, timeout, enforce)
+		if err != nil {
+			var quotaErr *usage.ExecutionQuotaError
+			if errors.As(err, &quotaErr) {
+				s.finish(ctx, task, run, RunQuotaExceeded, "Skipped: "+quotaErr.Error())
+			} else {
+				s.finish(ctx, task, run, RunError, "Could not reserve execution minutes: "+err.Error())
+			}
+			return
+		}
+		reservationID = reservation.ID
+	}
+
+	if s.runner == nil {
+		s.releaseReservation(ctx, reservationID)
+		s.finish(ctx, task, run, RunError, "Code execution is currently unavailable")
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := s.runner.RunProjectCommand(runCtx, task.ProjectID, task.Command, timeout)
+	if err != nil {
+		s.releaseReservation(ctx, reservationID)
+		s.finish(ctx, task, run, RunError, err.Error())
+		return
+	}
+	if reservationID != "" {
+		projectID := task.ProjectID
+		if _, err := s.quota.SettleExecution(ctx, reservationID, &projectID, result.DurationMs, result.CPUTime); err != nil {
+			log.Printf("schedules: failed to settle execution for task %d: %v", task.ID, err)
+		}
+	}
+
+	run.ExecutionID = result.ID
+	run.ExitCode = result.ExitCode
+	run.Output = truncateLog(result.Output)
+	run.DurationMs = result.DurationMs
+	s.finish(ctx, task, run, result.Status, result.ErrorOutput)
+}
+
+func (s *Service) releaseReservation(ctx context.Context, reservationID string) {
+	if reservationID == "" {
+		return
+	}
+	if err := s.quota.ReleaseExecution(ctx, reservationID); err != nil {
+		log.Printf("schedules: failed to release reservation %s: %v", reservationID, err)
+	}
+}
+
+// BillingFor returns the plan and enforcement used to reserve execution
+// minutes for background runs. It mirrors the request-time quota checks:
+// lapsed subscriptions get free limits and privileged accounts are not
+// enforced.
+func BillingFor(ctx context.Context, db *gorm.DB, userID uint) (usage.PlanType, bool) {
+	var user models.User
+	if err := db.WithContext(ctx).
+		Select("subscription_type", "subscription_status", "is_admin", "is_super_admin", "has_unlimited_credits", "bypass_billing").
+		First(&user, userID).Error; err != nil {
+		return usage.PlanFree, true
+	}
+	plan := usage.PlanType(user.SubscriptionType)
+	switch user.SubscriptionStatus {
+	case "past_due", "canceled", "inactive":
+		plan = usage.PlanFree
+	}
+	if plan == "" {
+		plan = usage.PlanFree
+	}
+	enforce := !(user.IsAdmin || user.IsSuperAdmin || user.HasUnlimitedCredits || user.BypassBilling)
+	return plan, enforce
+}
+
+// finish stores the run outcome, updates the task's streak, notifies on the
+// first failure, and disables the task after AutoDisableAfter failures.
+func (s *Service) finish(ctx context.Context, task *Task, run *Run, status, errorOutput string) {
+	now := time.Now().UTC()
+	run.Status = status
+	run.ErrorOutput = truncateLog(errorOutput)
+	run.CompletedAt = &now
+	if run.DurationMs == 0 {
+		run.DurationMs = now.Sub(run.StartedAt).Milliseconds()
+	}
+	if err := s.db.WithContext(ctx).Save(run).Error; err != nil {
+		log.Printf("schedules: failed to save run %d: %v", run.ID, err)
+	}
+
+	// Reload so concurrent edits to the task are not overwritten.
+	var current Task
+	if err := s.db.WithContext(ctx).First(&current, task.ID).Error; err != nil {
+		return
+	}
+	updates := map[string]any{"last_run_at": now, "last_status": status}
+	failures := 0
+	if !run.Succeeded() {
+		failures = current.ConsecutiveFailures + 1
+	}
+	updates["consecutive_failures"] = failures
+	disabled := failures >= AutoDisableAfter && current.Enabled
+	if disabled {
+		updates["enabled"] = false
+		updates["next_run_at"] = nil
+	}
+	if err := s.db.WithContext(ctx).Model(&Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		log.Printf("schedules: failed to update task %d: %v", task.ID, err)
+	}
+
+	switch {
+	case disabled:
+		s.notify(ctx, &current, run, notifications.SeverityCritical,
+			fmt.Sprintf("Scheduled task %q disabled", current.Name),
+			fmt.Sprintf("%q failed %d times in a row and has been disabled. Fix the command and re-enable it.", current.Name, failures))
+	case failures == 1:
+		s.notify(ctx, &current, run, notifications.SeverityWarning,
+			fmt.Sprintf("Scheduled task %q failed", current.Name),
+			fmt.Sprintf("Run #%d ended with status %s (exit code %d).", run.ID, status, run.ExitCode))
+	}
+
+	s.pruneRuns(ctx, task.ID)
+}
+
+func (s *Service) notify(ctx context.Context, task *Task, run *Run, severity, title, body string) {
+	if s.notifier == nil {
+		return
+	}
+	err := s.notifier.Notify(ctx, &notifications.Notification{
+		UserID:   task.UserID,
+		Kind:     "scheduled_task_failed",
+		Severity: severity,
+		Title:    title,
+		Body:     body,
+		Metadata: map[string]any{
+			"project_id": task.ProjectID,
+			"task_id":    task.ID,
+			"run_id":     run.ID,
+			"status":     run.Status,
+		},
+	})
+	if err != nil {
+		log.Printf("schedules: notify failed for task %d: %v", task.ID, err)
+	}
+}
+
+// pruneRuns keeps the newest RunHistoryLimit runs of a task.
+func (s *Service) pruneRuns(ctx context.Context, taskID uint) {
+	var cutoff Run
+	err := s.db.WithContext(ctx).Select("id").Where("task_id = ?", taskID).
+		Order("id DESC").Offset(RunHistoryLimit).Limit(1).First(&cutoff).Error
+	if err != nil {
+		return
+	}
+	s.db.WithContext(ctx).Where("task_id = ? AND id <= ?", taskID, cutoff.ID).Delete(&Run{})
+}
+
+func truncateLog(s string) string {
+	if len(s) <= maxLogBytes {
+		return s
+	}
+	return "[truncated: showing the last 64KB]\n" + s[len(s)-maxLogBytes:]
+}
@@ -0,0 +1,250 @@
+package schedules
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"apex-build/internal/execution"
+	"apex-build/internal/notifications"
+	"apex-build/internal/usage"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func mustNext(t *testing.T, expr string, from time.Time) time.Time {
+	t.Helper()
+	s, err := ParseCron(expr)
+	if err != nil {
+		t.Fatalf("ParseCron(%q): %v", expr, err)
+	}
+	return s.Next(from)
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 3, 14, 10, 7, 30, 0, time.UTC) // Saturday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * 7", time.Date(2026, 3, 15, 9, 30, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 20th or any Monday, whichever is first.
+		{"0 0 20 * 1", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		if got := mustNext(t, tc.expr, from); !got.Equal(tc.want) {
+			t.Errorf("Next(%q) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	if got := mustNext(t, "0 0 30 2 *", from); !got.IsZero() {
+		t.Errorf("Feb 30 should never fire, got %v", got)
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("ParseCron(%q) should fail", bad)
+		}
+	}
+}
+
+type fakeRunner struct {
+	mu      sync.Mutex
+	calls   int
+	release chan struct{}
+	result  execution.ExecutionResult
+}
+
+func (f *fakeRunner) RunProjectCommand(ctx context.Context, projectID uint, command string, timeout time.Duration) (*execution.ExecutionResult, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if f.release != nil {
+		<-f.release
+	}
+	result := f.result
+	return &result, nil
+}
+
+type fakeQuota struct {
+	exceeded bool
+	settled  []string
+}
+
+func (f *fakeQuota) ReserveExecution(ctx context.Context, userID uint, plan usage.PlanType, timeout time.Duration, enforce bool) (*usage.ExecutionReservation, error) {
+	if f.exceeded {
+		return nil, &usage.ExecutionQuotaError{Used: 60, Requested: 5, Limit: 60}
+	}
+	return &usage.ExecutionReservation{ID: "res-1", UserID: userID}, nil
+}
+
+func (f *fakeQuota) SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*usage.ExecutionReservation, error) {
+	f.settled = append(f.settled, reservationID)
+	return &usage.ExecutionReservation{ID: reservationID}, nil
+}
+
+func (f *fakeQuota) ReleaseExecution(ctx context.Context, reservationID string) error { return nil }
+
+type fakeNotifier struct{ sent []*notifications.Notification }
+
+func (f *fakeNotifier) Notify(ctx context.Context, n *notifications.Notification) error {
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+func setupScheduleTest(t *testing.T) (*Service, *gorm.DB, *Task, *fakeRunner, *fakeQuota, *fakeNotifier) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate schedules: %v", err)
+	}
+	name := strings.ToLower(t.Name())
+	user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	project := models.Project{Name: "app", Language: "python", OwnerID: user.ID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	runner := &fakeRunner{result: execution.ExecutionResult{ID: "exec-1", Status: "completed", Output: "ok\n", DurationMs: 1200}}
+	quota := &fakeQuota{}
+	notifier := &fakeNotifier{}
+	svc := NewService(db)
+	svc.SetRunner(runner)
+	svc.SetQuota(quota)
+	svc.SetNotifier(notifier)
+
+	cmd, cron := "python backup.py", "*/5 * * * *"
+	task, err := svc.Create(context.Background(), user.ID, project.ID, TaskInput{Name: &cmd, Command: &cmd, Cron: &cron})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return svc, db, task, runner, quota, notifier
+}
+
+func runDueNow(t *testing.T, svc *Service, db *gorm.DB, taskID uint) int {
+	t.Helper()
+	past := time.Now().UTC().Add(-time.Minute)
+	db.Model(&Task{}).Where("id = ?", taskID).Update("next_run_at", past)
+	started, err := svc.RunDue(context.Background(), time.Now().UTC())
+	if err != nil {
+		t.Fatalf("RunDue: %v", err)
+	}
+	svc.Wait()
+	return started
+}
+
+func TestRunDueRecordsRunAndSettlesQuota(t *testing.T) {
+	svc, db, task, runner, quota, notifier := setupScheduleTest(t)
+	ctx := context.Background()
+
+	if started := runDueNow(t, svc, db, task.ID); started != 1 || runner.calls != 1 {
+		t.Fatalf("started = %d, runner calls = %d", started, runner.calls)
+	}
+	// The claim advanced next_run_at, so a second tick is a no-op.
+	if started, _ := svc.RunDue(ctx, time.Now().UTC()); started != 0 {
+		t.Fatalf("task ran twice in one slot")
+	}
+
+	runs, err := svc.ListRuns(ctx, task.UserID, task.ID, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	if runs[0].Output != "" {
+		t.Fatal("run list should omit logs")
+	}
+	run, err := svc.GetRun(ctx, task.UserID, task.ID, runs[0].ID)
+	if err != nil || run.Status != "completed" || run.Output != "ok\n" || run.ExecutionID != "exec-1" {
+		t.Fatalf("run = %+v, %v", run, err)
+	}
+	if len(quota.settled) != 1 || len(notifier.sent) != 0 {
+		t.Fatalf("settled = %v, notifications = %d", quota.settled, len(notifier.sent))
+	}
+	updated, _ := svc.Get(ctx, task.UserID, task.ID)
+	if updated.LastStatus != "completed" || updated.NextRunAt == nil || !updated.NextRunAt.After(time.Now()) {
+		t.Fatalf("task = %+v", updated)
+	}
+}
+
+func TestOverlappingRunIsSkipped(t *testing.T) {
+	svc, db, task, runner, _, _ := setupScheduleTest(t)
+	runner.release = make(chan struct{})
+	ctx := context.Background()
+
+	if _, err := svc.RunNow(ctx, task.UserID, task.ID); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if _, err := svc.RunNow(ctx, task.UserID, task.ID); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("second RunNow err = %v", err)
+	}
+	db.Model(&Task{}).Where("id = ?", task.ID).Update("next_run_at", time.Now().UTC().Add(-time.Minute))
+	if started, _ := svc.RunDue(ctx, time.Now().UTC()); started != 0 {
+		t.Fatalf("overlapping run started")
+	}
+	close(runner.release)
+	svc.Wait()
+
+	var statuses []string
+	db.Model(&Run{}).Where("task_id = ?", task.ID).Order("id").Pluck("status", &statuses)
+	if strings.Join(statuses, ",") != "completed,skipped" {
+		t.Fatalf("statuses = %v", statuses)
+	}
+}
+
+func TestFailuresNotifyAndAutoDisable(t *testing.T) {
+	svc, db, task, runner, _, notifier := setupScheduleTest(t)
+	runner.result.Status = "failed"
+	runner.result.ExitCode = 1
+
+	for i := 0; i < AutoDisableAfter; i++ {
+		runDueNow(t, svc, db, task.ID)
+	}
+	if len(notifier.sent) != 2 {
+		t.Fatalf("notifications = %d, want first failure and disable", len(notifier.sent))
+	}
+	if notifier.sent[0].Severity != notifications.SeverityWarning || notifier.sent[1].Severity != notifications.SeverityCritical {
+		t.Fatalf("severities = %s, %s", notifier.sent[0].Severity, notifier.sent[1].Severity)
+	}
+	disabled, _ := svc.Get(context.Background(), task.UserID, task.ID)
+	if disabled.Enabled || disabled.NextRunAt != nil || disabled.ConsecutiveFailures != AutoDisableAfter {
+		t.Fatalf("task after failures = %+v", disabled)
+	}
+
+	enabled := true
+	reenabled, err := svc.Update(context.Background(), task.UserID, task.ID, TaskInput{Enabled: &enabled})
+	if err != nil || reenabled.ConsecutiveFailures != 0 || reenabled.NextRunAt == nil {
+		t.Fatalf("re-enabled task = %+v, %v", reenabled, err)
+	}
+}
+
+func TestQuotaExceededSkipsExecution(t *testing.T) {
+	svc, db, task, runner, quota, _ := setupScheduleTest(t)
+	quota.exceeded = true
+
+	runDueNow(t, svc, db, task.ID)
+	if runner.calls != 0 {
+		t.Fatal("runner should not be called without quota")
+	}
+	var run Run
+	db.Where("task_id = ?", task.ID).First(&run)
+	if run.Status != RunQuotaExceeded || !strings.Contains(run.ErrorOutput, "execution quota exceeded") {
+		t.Fatalf("run = %+v", run)
+	}
+}
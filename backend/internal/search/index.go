@@ -0,0 +1,337 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultIndexInterval is how often the indexer picks up changed files.
+	DefaultIndexInterval = 30 * time.Second
+
+	// IndexMinProjectFiles is the project size from which content searches
+	// are narrowed through the index. Smaller projects are scanned in full.
+	IndexMinProjectFiles = 500
+
+	// maxIndexedBytes keeps tsvectors well under Postgres' 1MB limit. Larger
+	// text files are always scanned.
+	maxIndexedBytes = 256 << 10
+
+	indexBatchSize  = 200
+	indexMaxBatches = 50
+
+	// indexHealthyLag is how far behind the index may fall before it is
+	// reported unhealthy
+	indexHealthyLag = 5 * time.Minute
+
+	// indexRankWeight scales ts_rank (0..1) into relevance score points
+	indexRankWeight = 100.0
+)
+
+// rankWeights are ts_rank weights for {D, C, B, A}: file names (A) outrank
+// paths (B), which outrank content (C)
+const rankWeights = "'{0.05, 0.2, 0.5, 1.0}'"
+
+var (
+	indexRefreshedFiles = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "apex",
+		Subsystem: "search_index",
+		Name:      "refreshed_files_total",
+		Help:      "Files (re)indexed by the search indexer",
+	})
+	indexPendingFiles = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "apex",
+		Subsystem: "search_index",
+		Name:      "pending_files",
+		Help:      "Searchable files whose index entry is missing or out of date",
+	})
+)
+
+// pendingCondition matches files whose index entry is missing, out of date
+// or marked for rebuild. Oversized files are never indexed.
+const pendingCondition = `(i.file_id IS NULL OR i.stale OR i.source_updated_at IS DISTINCT FROM f.updated_at)`
+
+// searchableFiles are the files Search reads: live text files under 1MB
+const searchableFiles = `f.deleted_at IS NULL AND f.type <> 'directory' AND f.mime_type LIKE 'text/%' AND f.size < 1048576`
+
+var indexSchema = []string{
+	`CREATE TABLE IF NOT EXISTS file_search_index (
+		file_id BIGINT PRIMARY KEY,
+		project_id BIGINT NOT NULL,
+		source_updated_at TIMESTAMPTZ,
+		indexed_at TIMESTAMPTZ,
+		stale BOOLEAN NOT NULL DEFAULT FALSE,
+		search_vector TSVECTOR
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_file_search_index_project_id ON file_search_index(project_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_file_search_index_vector ON file_search_index USING GIN(search_vector)`,
+}
+
+// IndexStats reports how complete and current the search index is.
+type IndexStats struct {
+	Enabled   bool  `json:"enabled"`
+	ProjectID uint  `json:"project_id,omitempty"`
+	Files     int64 `json:"files"`     // searchable text files
+	Indexed   int64 `json:"indexed"`   // with a current index entry
+	Pending   int64 `json:"pending"`   // new, changed or marked for rebuild
+	Oversized int64 `json:"oversized"` // too large to index; always scanned
+	// OldestPendingAt is when the longest-waiting pending file changed
+	OldestPendingAt  *time.Time `json:"oldest_pending_at,omitempty"`
+	LagSeconds       float64    `json:"lag_seconds"`
+	LastRefreshAt    *time.Time `json:"last_refresh_at,omitempty"`
+	LastRefreshError string     `json:"last_refresh_error,omitempty"`
+	Healthy          bool       `json:"healthy"`
+}
+
+// Indexer maintains file_search_index: a weighted tsvector per file,
+// refreshed in batches as files change. It only runs on PostgreSQL; on
+// other databases search scans file content directly.
+type Indexer struct {
+	db  *gorm.DB
+	now func() time.Time
+
+	mu            sync.Mutex
+	lastRefreshAt time.Time
+	lastErr       string
+}
+
+// NewIndexer creates a new Indexer backed by the given database.
+func NewIndexer(db *gorm.DB) *Indexer {
+	return &Indexer{db: db, now: time.Now}
+}
+
+// Enabled reports whether the index is available on this database.
+func (ix *Indexer) Enabled() bool {
+	return ix != nil && ix.db != nil && ix.db.Dialector.Name() == "postgres"
+}
+
+// EnsureSchema creates the index table. It is a no-op when disabled.
+func (ix *Indexer) EnsureSchema(ctx context.Context) error {
+	if !ix.Enabled() {
+		return nil
+	}
+	for _, stmt := range indexSchema {
+		if err := ix.db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return fmt.Errorf("search index: create schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Refresh indexes new and changed files and drops entries for deleted ones.
+// It returns how many files were indexed.
+func (ix *Indexer) Refresh(ctx context.Context) (int64, error) {
+	if !ix.Enabled() {
+		return 0, nil
+	}
+	db := ix.db.WithContext(ctx)
+	var total int64
+	err := func() error {
+		for range indexMaxBatches {
+			res := db.Exec(`
+				WITH batch AS (
+					SELECT f.id FROM files f
+					LEFT JOIN file_search_index i ON i.file_id = f.id
+					WHERE `+searchableFiles+` AND f.size < ? AND `+pendingCondition+`
+					ORDER BY f.updated_at
+					LIMIT ?
+				)
+				INSERT INTO file_search_index (file_id, project_id, source_updated_at, indexed_at, stale, search_vector)
+				SELECT f.id, f.project_id, f.updated_at, NOW(), FALSE,
+					setweight(to_tsvector('simple', `+identifierWords("f.name")+`), 'A') ||
+					setweight(to_tsvector('simple', `+identifierWords("f.path")+`), 'B') ||
+					setweight(to_tsvector('simple', `+identifierWords("f.content")+`), 'C')
+				FROM files f JOIN batch b ON b.id = f.id
+				ON CONFLICT (file_id) DO UPDATE SET
+					project_id = EXCLUDED.project_id,
+					source_updated_at = EXCLUDED.source_updated_at,
+					indexed_at = EXCLUDED.indexed_at,
+					stale = FALSE,
+					search_vector = EXCLUDED.search_vector`,
+				maxIndexedBytes, indexBatchSize)
+			if res.Error != nil {
+				return fmt.Errorf("search index: refresh: %w", res.Error)
+			}
+			total += res.RowsAffected
+			indexRefreshedFiles.Add(float64(res.RowsAffected))
+			if res.RowsAffected < indexBatchSize {
+				break
+			}
+		}
+		if err := db.Exec(`
+			DELETE FROM file_search_index i
+			WHERE NOT EXISTS (
+				SELECT 1 FROM files f
+				WHERE f.id = i.file_id AND `+searchableFiles+` AND f.size < ?
+			)`, maxIndexedBytes).Error; err != nil {
+			return fmt.Errorf("search index: drop deleted files: %w", err)
+		}
+		return nil
+	}()
+
+	ix.mu.Lock()
+	ix.lastRefreshAt = ix.now().UTC()
+	ix.lastErr = ""
+	if err != nil {
+		ix.lastErr = err.Error()
+	}
+	ix.mu.Unlock()
+	return total, err
+}
+
+// Rebuild marks a project's entries, or every entry when projectID is 0, for
+// reindexing on the next refresh. Stale entries are still searched by scan.
+func (ix *Indexer) Rebuild(ctx context.Context, projectID uint) (int64, error) {
+	if !ix.Enabled() {
+		return 0, nil
+	}
+	db := ix.db.WithContext(ctx).Table("file_search_index")
+	if projectID > 0 {
+		db = db.Where("project_id = ?", projectID)
+	} else {
+		db = db.Where("1 = 1")
+	}
+	res := db.Update("stale", true)
+	if res.Error != nil {
+		return 0, fmt.Errorf("search index: rebuild: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// Stats reports coverage and lag for one project, or all projects when
+// projectID is 0.
+func (ix *Indexer) Stats(ctx context.Context, projectID uint) (*IndexStats, error) {
+	stats := &IndexStats{Enabled: ix.Enabled(), ProjectID: projectID}
+	if !stats.Enabled {
+		return stats, nil
+	}
+
+	var row struct {
+		Files           int64
+		Oversized       int64
+		Indexed         int64
+		OldestPendingAt *time.Time
+	}
+	where := searchableFiles
+	args := []any{maxIndexedBytes, maxIndexedBytes, maxIndexedBytes}
+	if projectID > 0 {
+		where += " AND f.project_id = ?"
+		args = append(args, projectID)
+	}
+	if err := ix.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*) AS files,
+			COALESCE(SUM(CASE WHEN f.size >= ? THEN 1 ELSE 0 END), 0) AS oversized,
+			COALESCE(SUM(CASE WHEN f.size < ? AND NOT `+pendingCondition+` THEN 1 ELSE 0 END), 0) AS indexed,
+			MIN(CASE WHEN f.size < ? AND `+pendingCondition+` THEN f.updated_at END) AS oldest_pending_at
+		FROM files f
+		LEFT JOIN file_search_index i ON i.file_id = f.id
+		WHERE `+where, args...).Scan(&row).Error; err != nil {
+		return nil, fmt.Errorf("search index: stats: %w", err)
+	}
+
+	now := ix.now().UTC()
+	stats.Files, stats.Oversized, stats.Indexed = row.Files, row.Oversized, row.Indexed
+	stats.Pending = row.Files - row.Oversized - row.Indexed
+	stats.OldestPendingAt = row.OldestPendingAt
+	if row.OldestPendingAt != nil {
+		stats.LagSeconds = max(now.Sub(*row.OldestPendingAt).Seconds(), 0)
+	}
+	if projectID == 0 {
+		indexPendingFiles.Set(float64(stats.Pending))
+	}
+
+	ix.mu.Lock()
+	if !ix.lastRefreshAt.IsZero() {
+		last := ix.lastRefreshAt
+		stats.LastRefreshAt = &last
+	}
+	stats.LastRefreshError = ix.lastErr
+	ix.mu.Unlock()
+	stats.Healthy = stats.LastRefreshError == "" && stats.LagSeconds < indexHealthyLag.Seconds()
+	return stats, nil
+}
+
+// Start refreshes the index on a ticker until ctx is cancelled. It is a
+// no-op when the index is disabled.
+func (ix *Indexer) Start(ctx context.Context, interval time.Duration) {
+	if !ix.Enabled() {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultIndexInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := ix.Refresh(ctx); err != nil {
+					log.Printf("search index: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// identifierWords is the SQL splitting column into the words of its
+// identifiers, so "user.getName()" indexes as "user get name". Left as is,
+// the Postgres parser would keep "user.getName" as one host-like token.
+func identifierWords(column string) string {
+	return `regexp_replace(regexp_replace(COALESCE(` + column + `, ''), '([a-z0-9])([A-Z])', '\1 \2', 'g'), '[^A-Za-z0-9]+', ' ', 'g')`
+}
+
+var (
+	camelBoundary  = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	nonWordPattern = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// prefixTSQuery turns a plain search into a to_tsquery expression matching
+// each identifier word as a prefix, e.g. "getUser(id" -> "get:* & user:* &
+// id:*". It returns "" when the query has no words to match.
+func prefixTSQuery(query string) string {
+	split := nonWordPattern.ReplaceAllString(camelBoundary.ReplaceAllString(query, "$1 $2"), " ")
+	words := strings.Fields(strings.ToLower(split))
+	if len(words) > 8 {
+		words = words[:8]
+	}
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// candidateFilter narrows a project's files to index matches for large
+// projects. Files the index cannot vouch for (pending or oversized) are kept
+// so results never go stale. It returns the tsquery, or "" when the query
+// should scan every file.
+func (ix *Indexer) candidateFilter(ctx context.Context, query *SearchQuery) string {
+	if !ix.Enabled() || query.ProjectID == 0 || query.UseRegex {
+		return ""
+	}
+	if query.SearchType != "all" && query.SearchType != "content" {
+		return ""
+	}
+	tsQuery := prefixTSQuery(query.Query)
+	if tsQuery == "" {
+		return ""
+	}
+	var files int64
+	if err := ix.db.WithContext(ctx).Table("files").
+		Where("project_id = ? AND deleted_at IS NULL", query.ProjectID).
+		Count(&files).Error; err != nil || files < IndexMinProjectFiles {
+		return ""
+	}
+	return tsQuery
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPrefixTSQuery(t *testing.T) {
+	for query, want := range map[string]string{
+		"handleLogin":       "handle:* & login:*",
+		"user.getName(":     "user:* & get:* & name:*",
+		"max_retries 3":     "max:* & retries:* & 3:*",
+		"HTTPServer":        "httpserver:*",
+		"  ->  ":            "",
+		"a b c d e f g h i": "a:* & b:* & c:* & d:* & e:* & f:* & g:* & h:*",
+	} {
+		if got := prefixTSQuery(query); got != want {
+			t.Errorf("prefixTSQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestSearchScansWithoutIndex(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for _, file := range []models.File{
+		{ProjectID: 1, Path: "src/login.go", Name: "login.go", Type: "file", MimeType: "text/x-go", Content: "func handleLogin() {}\n"},
+		{ProjectID: 1, Path: "src/user.go", Name: "user.go", Type: "file", MimeType: "text/x-go", Content: "var login = handleLogin\n"},
+		{ProjectID: 2, Path: "main.go", Name: "main.go", Type: "file", MimeType: "text/x-go", Content: "handleLogin()\n"},
+	} {
+		if err := db.Create(&file).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+
+	indexer := NewIndexer(db)
+	if indexer.Enabled() {
+		t.Fatal("index enabled on sqlite")
+	}
+	engine := NewSearchEngine(db)
+	engine.SetIndexer(indexer)
+
+	results, err := engine.Search(context.Background(), &SearchQuery{Query: "handleLogin", ProjectID: 1, FileTypes: []string{".go"}})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if results.FileMatches != 2 || results.Stats.Indexed {
+		t.Fatalf("results = %d files, indexed %v", results.FileMatches, results.Stats.Indexed)
+	}
+
+	stats, err := indexer.Stats(context.Background(), 1)
+	if err != nil || stats.Enabled {
+		t.Fatalf("stats = %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,512 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"apex-build/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// ReplaceUndoWindow is how long an applied replacement can be undone.
+	ReplaceUndoWindow = 24 * time.Hour
+
+	// maxPreviewMatches caps the matches a preview lists; applying without
+	// a selection still replaces every match
+	maxPreviewMatches = 5000
+
+	// previewContext is how much of the line either side of a match a
+	// preview shows
+	previewContext = 120
+)
+
+var (
+	// ErrInvalidPattern is returned for searches that do not compile.
+	ErrInvalidPattern = errors.New("invalid search pattern")
+	// ErrReplaceConflict is returned when files changed since they were
+	// previewed, or since a replacement being undone; nothing is written.
+	ErrReplaceConflict = errors.New("files changed since the replacement was previewed")
+	// ErrUndoNotFound is returned for unknown undo tokens.
+	ErrUndoNotFound = errors.New("replacement not found")
+	// ErrUndoExpired is returned for undo tokens past ReplaceUndoWindow.
+	ErrUndoExpired = errors.New("replacement can no longer be undone")
+	// ErrAlreadyUndone is returned when a replacement was already undone.
+	ErrAlreadyUndone = errors.New("replacement was already undone")
+)
+
+// VersionRecorder records a file version and returns its ID. Implemented by
+// handlers.CreateFileVersion.
+type VersionRecorder func(db *gorm.DB, file *models.File, authorID uint, authorName, changeType, summary string) uint
+
+// ReplaceSpec is what a replacement matches and what it writes. With
+// UseRegex, Replace may refer to capture groups as $1 or ${name}; otherwise
+// it is inserted literally.
+type ReplaceSpec struct {
+	ProjectID     uint
+	Search        string
+	Replace       string
+	CaseSensitive bool
+	WholeWord     bool
+	UseRegex      bool
+	FileTypes     []string
+	Paths         []string
+}
+
+// ReplaceMatch is one match with the line around it before and after.
+type ReplaceMatch struct {
+	ID          string `json:"id"` // byte range "start-end", stable while the file is unchanged
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Matched     string `json:"matched"`
+	Replacement string `json:"replacement"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+}
+
+// ReplaceFilePreview lists the matches in one file. ContentHash identifies
+// the previewed content; pass it back when applying.
+type ReplaceFilePreview struct {
+	FileID      uint            `json:"file_id"`
+	FilePath    string          `json:"file_path"`
+	ContentHash string          `json:"content_hash"`
+	MatchCount  int             `json:"match_count"`
+	Matches     []*ReplaceMatch `json:"matches"`
+}
+
+// ReplacePreview is every match a replacement would change.
+type ReplacePreview struct {
+	Search     string                `json:"search"`
+	Replace    string                `json:"replace"`
+	FileCount  int                   `json:"file_count"`
+	MatchCount int                   `json:"match_count"`
+	Truncated  bool                  `json:"truncated"` // more matches than are listed
+	Files      []*ReplaceFilePreview `json:"files"`
+}
+
+// ReplaceSelection picks the matches to apply in one file. No MatchIDs
+// applies every match in the file.
+type ReplaceSelection struct {
+	FileID      uint     `json:"file_id"`
+	ContentHash string   `json:"content_hash"`
+	MatchIDs    []string `json:"match_ids"`
+}
+
+// ReplaceConflict is a file that changed since it was previewed, or was
+// deleted.
+type ReplaceConflict struct {
+	FileID   uint   `json:"file_id"`
+	FilePath string `json:"file_path,omitempty"`
+	Reason   string `json:"reason"` // changed, deleted
+}
+
+// ReplacedFile is one file an applied replacement changed.
+type ReplacedFile struct {
+	FileID          uint   `json:"file_id"`
+	FilePath        string `json:"file_path"`
+	Replacements    int    `json:"replacements"`
+	BeforeVersionID uint   `json:"before_version_id"`
+	AfterHash       string `json:"after_hash"`
+}
+
+// ReplaceOperation is an applied replacement, kept so it can be undone
+// through the file versions recorded before it.
+type ReplaceOperation struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Token         string         `gorm:"uniqueIndex;size:36;not null" json:"undo_token"`
+	ProjectID     uint           `gorm:"index;not null" json:"project_id"`
+	UserID        uint           `gorm:"not null" json:"user_id"`
+	Search        string         `gorm:"type:text" json:"search"`
+	Replace       string         `gorm:"type:text" json:"replace"`
+	FilesModified int            `json:"files_modified"`
+	TotalReplaces int            `json:"total_replaces"`
+	Files         []ReplacedFile `gorm:"serializer:json;type:text" json:"files"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	UndoneAt      *time.Time     `json:"undone_at,omitempty"`
+}
+
+func (ReplaceOperation) TableName() string { return "replace_operations" }
+
+// ReplaceApplyResult reports an applied replacement. UndoToken is empty
+// when nothing changed or file versions are not recorded.
+type ReplaceApplyResult struct {
+	UndoToken     string            `json:"undo_token,omitempty"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty"`
+	FilesModified int               `json:"files_modified"`
+	TotalReplaces int               `json:"total_replaces"`
+	Files         []ReplacedFile    `json:"files"`
+	Conflicts     []ReplaceConflict `json:"conflicts,omitempty"`
+}
+
+// ReplaceUndoResult reports an undone replacement.
+type ReplaceUndoResult struct {
+	FilesRestored int               `json:"files_restored"`
+	Conflicts     []ReplaceConflict `json:"conflicts,omitempty"`
+}
+
+// AutoMigrate creates the replace_operations table.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&ReplaceOperation{})
+}
+
+// SetVersionRecorder records a version before and after each file a
+// replacement changes, which is what makes replacements undoable.
+func (e *SearchEngine) SetVersionRecorder(r VersionRecorder) {
+	e.versions = r
+}
+
+// PreviewReplace lists every match a replacement would change, with the
+// line before and after, without writing anything.
+func (e *SearchEngine) PreviewReplace(ctx context.Context, spec ReplaceSpec) (*ReplacePreview, error) {
+	re, err := spec.pattern()
+	if err != nil {
+		return nil, err
+	}
+	files, err := e.getFilesToSearch(ctx, spec.query())
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ReplacePreview{Search: spec.Search, Replace: spec.Replace, Files: make([]*ReplaceFilePreview, 0)}
+	for _, file := range files {
+		matches := spec.matches(re, file.Content)
+		if len(matches) == 0 {
+			continue
+		}
+		filePreview := &ReplaceFilePreview{
+			FileID:      file.ID,
+			FilePath:    file.Path,
+			ContentHash: contentHash(file.Content),
+			MatchCount:  len(matches),
+			Matches:     make([]*ReplaceMatch, 0, len(matches)),
+		}
+		for _, m := range matches {
+			if preview.MatchCount >= maxPreviewMatches {
+				preview.Truncated = true
+				break
+			}
+			filePreview.Matches = append(filePreview.Matches, m.render(file.Content))
+			preview.MatchCount++
+		}
+		preview.Files = append(preview.Files, filePreview)
+		preview.FileCount++
+	}
+	return preview, nil
+}
+
+// ApplyReplace replaces the selected matches, or every match when
+// selections is nil. Files whose content no longer matches the previewed
+// hash are reported as conflicts and nothing is written.
+func (e *SearchEngine) ApplyReplace(ctx context.Context, spec ReplaceSpec, selections []ReplaceSelection, userID uint, authorName string) (*ReplaceApplyResult, error) {
+	re, err := spec.pattern()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []models.File
+	selected := make(map[uint]ReplaceSelection, len(selections))
+	if selections == nil {
+		if files, err = e.getFilesToSearch(ctx, spec.query()); err != nil {
+			return nil, err
+		}
+	} else {
+		ids := make([]uint, 0, len(selections))
+		for _, sel := range selections {
+			selected[sel.FileID] = sel
+			ids = append(ids, sel.FileID)
+		}
+		if len(ids) > 0 {
+			if err := e.db.WithContext(ctx).Where("project_id = ? AND id IN ?", spec.ProjectID, ids).Find(&files).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &ReplaceApplyResult{Files: make([]ReplacedFile, 0)}
+	found := make(map[uint]bool, len(files))
+	for _, file := range files {
+		found[file.ID] = true
+		if sel, ok := selected[file.ID]; ok && sel.ContentHash != "" && sel.ContentHash != contentHash(file.Content) {
+			result.Conflicts = append(result.Conflicts, ReplaceConflict{FileID: file.ID, FilePath: file.Path, Reason: "changed"})
+		}
+	}
+	for _, sel := range selections {
+		if !found[sel.FileID] {
+			result.Conflicts = append(result.Conflicts, ReplaceConflict{FileID: sel.FileID, Reason: "deleted"})
+		}
+	}
+	if len(result.Conflicts) > 0 {
+		return result, ErrReplaceConflict
+	}
+
+	now := time.Now()
+	err = e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range files {
+			file := &files[i]
+			matches := spec.matches(re, file.Content)
+			if sel, ok := selected[file.ID]; ok && len(sel.MatchIDs) > 0 {
+				matches = filterMatches(matches, sel.MatchIDs)
+			}
+			if len(matches) == 0 {
+				continue
+			}
+
+			var beforeVersionID uint
+			if e.versions != nil {
+				beforeVersionID = e.versions(tx, file, userID, authorName, "pre-replace", fmt.Sprintf("Before replacing %q", spec.Search))
+			}
+			file.Content = applyMatches(file.Content, matches)
+			file.Size = int64(len(file.Content))
+			err := tx.Model(file).Updates(map[string]any{
+				"content":      file.Content,
+				"size":         file.Size,
+				"last_edit_by": userID,
+				"version":      gorm.Expr("version + 1"),
+				"updated_at":   now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if e.versions != nil {
+				e.versions(tx, file, userID, authorName, "replace", fmt.Sprintf("Replaced %d of %q with %q", len(matches), spec.Search, spec.Replace))
+			}
+
+			result.Files = append(result.Files, ReplacedFile{
+				FileID:          file.ID,
+				FilePath:        file.Path,
+				Replacements:    len(matches),
+				BeforeVersionID: beforeVersionID,
+				AfterHash:       contentHash(file.Content),
+			})
+			result.FilesModified++
+			result.TotalReplaces += len(matches)
+		}
+
+		if result.FilesModified == 0 || e.versions == nil {
+			return nil
+		}
+		op := &ReplaceOperation{
+			Token:         uuid.NewString(),
+			ProjectID:     spec.ProjectID,
+			UserID:        userID,
+			Search:        spec.Search,
+			Replace:       spec.Replace,
+			FilesModified: result.FilesModified,
+			TotalReplaces: result.TotalReplaces,
+			Files:         result.Files,
+			ExpiresAt:     now.Add(ReplaceUndoWindow),
+		}
+		if err := tx.Create(op).Error; err != nil {
+			return err
+		}
+		result.UndoToken = op.Token
+		result.ExpiresAt = &op.ExpiresAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UndoReplace restores the files an applied replacement changed to the
+// versions recorded before it. Files edited since are conflicts unless
+// force is set, in which case their current content is kept as a version.
+func (e *SearchEngine) UndoReplace(ctx context.Context, projectID uint, token string, force bool, userID uint, authorName string) (*ReplaceUndoResult, error) {
+	var op ReplaceOperation
+	err := e.db.WithContext(ctx).Where("token = ? AND project_id = ?", token, projectID).Take(&op).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUndoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case op.UndoneAt != nil:
+		return nil, ErrAlreadyUndone
+	case time.Now().After(op.ExpiresAt):
+		return nil, ErrUndoExpired
+	}
+
+	result := &ReplaceUndoResult{}
+	files := make(map[uint]*models.File, len(op.Files))
+	for _, replaced := range op.Files {
+		var file models.File
+		if err := e.db.WithContext(ctx).Where("id = ? AND project_id = ?", replaced.FileID, projectID).Limit(1).Find(&file).Error; err != nil {
+			return nil, err
+		}
+		switch {
+		case file.ID == 0:
+			result.Conflicts = append(result.Conflicts, ReplaceConflict{FileID: replaced.FileID, FilePath: replaced.FilePath, Reason: "deleted"})
+		case contentHash(file.Content) != replaced.AfterHash && !force:
+			result.Conflicts = append(result.Conflicts, ReplaceConflict{FileID: file.ID, FilePath: file.Path, Reason: "changed"})
+		default:
+			files[file.ID] = &file
+		}
+	}
+	if len(result.Conflicts) > 0 && !force {
+		return result, ErrReplaceConflict
+	}
+
+	now := time.Now()
+	err = e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, replaced := range op.Files {
+			file, ok := files[replaced.FileID]
+			if !ok {
+				continue
+			}
+			var version models.FileVersion
+			if err := tx.Where("id = ? AND file_id = ?", replaced.BeforeVersionID, file.ID).Take(&version).Error; err != nil {
+				return fmt.Errorf("load version %d of %s: %w", replaced.BeforeVersionID, file.Path, err)
+			}
+			if contentHash(file.Content) != replaced.AfterHash && e.versions != nil {
+				e.versions(tx, file, userID, authorName, "pre-restore", "State before undoing a replacement")
+			}
+			file.Content = version.Content
+			file.Size = int64(len(version.Content))
+			err := tx.Model(file).Updates(map[string]any{
+				"content":      file.Content,
+				"size":         file.Size,
+				"last_edit_by": userID,
+				"version":      gorm.Expr("version + 1"),
+				"updated_at":   now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if e.versions != nil {
+				e.versions(tx, file, userID, authorName, "restore", fmt.Sprintf("Undid replacing %q with %q", op.Search, op.Replace))
+			}
+			result.FilesRestored++
+		}
+		return tx.Model(&op).Update("undone_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pattern compiles the search. Whole-word matching wraps it in a group
+// that captures nothing, so $1 still names the first group of the search.
+func (spec ReplaceSpec) pattern() (*regexp.Regexp, error) {
+	expr := spec.Search
+	if !spec.UseRegex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if spec.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if !spec.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	return re, nil
+}
+
+func (spec ReplaceSpec) query() *SearchQuery {
+	return &SearchQuery{ProjectID: spec.ProjectID, FileTypes: spec.FileTypes, Paths: spec.Paths}
+}
+
+// replaceMatch is a match's byte range and its expanded replacement
+type replaceMatch struct {
+	start, end  int
+	replacement string
+}
+
+func (m replaceMatch) id() string {
+	return strconv.Itoa(m.start) + "-" + strconv.Itoa(m.end)
+}
+
+func (spec ReplaceSpec) matches(re *regexp.Regexp, content string) []replaceMatch {
+	var matches []replaceMatch
+	for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		replacement := spec.Replace
+		if spec.UseRegex {
+			replacement = string(re.ExpandString(nil, spec.Replace, content, loc))
+		}
+		matches = append(matches, replaceMatch{start: loc[0], end: loc[1], replacement: replacement})
+	}
+	return matches
+}
+
+// render shows a match on its line, before and after replacement
+func (m replaceMatch) render(content string) *ReplaceMatch {
+	lineStart := strings.LastIndexByte(content[:m.start], '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[m.end:], '\n'); i >= 0 {
+		lineEnd = m.end + i
+	}
+	prefix, suffix := content[lineStart:m.start], content[m.end:lineEnd]
+	if len(prefix) > previewContext {
+		prefix = "…" + trimToRuneStart(prefix[len(prefix)-previewContext:])
+	}
+	if len(suffix) > previewContext {
+		suffix = strings.ToValidUTF8(suffix[:previewContext], "") + "…"
+	}
+	matched := content[m.start:m.end]
+	return &ReplaceMatch{
+		ID:          m.id(),
+		Line:        strings.Count(content[:m.start], "\n") + 1,
+		Column:      utf8.RuneCountInString(content[lineStart:m.start]) + 1,
+		Matched:     matched,
+		Replacement: m.replacement,
+		Before:      prefix + matched + suffix,
+		After:       prefix + m.replacement + suffix,
+	}
+}
+
+func filterMatches(matches []replaceMatch, ids []string) []replaceMatch {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	kept := matches[:0]
+	for _, m := range matches {
+		if want[m.id()] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// applyMatches rewrites content with matches, which are in order and do
+// not overlap
+func applyMatches(content string, matches []replaceMatch) string {
+	var b strings.Builder
+	b.Grow(len(content))
+	last := 0
+	for _, m := range matches {
+		b.WriteString(content[last:m.start])
+		b.WriteString(m.replacement)
+		last = m.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+func trimToRuneStart(s string) string {
+	for len(s) > 0 && !utf8.RuneStart(s[0]) {
+		s = s[1:]
+	}
+	return s
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newReplaceTestEngine(t *testing.T) (*SearchEngine, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.File{}, &models.FileVersion{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate replace operations: %v", err)
+	}
+
+	engine := NewSearchEngine(db)
+	engine.SetVersionRecorder(func(tx *gorm.DB, file *models.File, authorID uint, authorName, changeType, summary string) uint {
+		version := &models.FileVersion{FileID: file.ID, ProjectID: file.ProjectID, Content: file.Content, ChangeType: changeType, ChangeSummary: summary, AuthorID: authorID}
+		if err := tx.Create(version).Error; err != nil {
+			t.Fatalf("record version: %v", err)
+		}
+		return version.ID
+	})
+	return engine, db
+}
+
+func TestReplacePreviewApplyAndUndo(t *testing.T) {
+	engine, db := newReplaceTestEngine(t)
+	ctx := context.Background()
+
+	original := "getUser(1)\nlog(getUser(2))\ngetUserName()\n"
+	files := []models.File{
+		{ProjectID: 1, Path: "a.js", Name: "a.js", Type: "file", MimeType: "text/javascript", Content: original},
+		{ProjectID: 1, Path: "b.js", Name: "b.js", Type: "file", MimeType: "text/javascript", Content: "getUser(3)\n"},
+	}
+	for i := range files {
+		if err := db.Create(&files[i]).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+
+	spec := ReplaceSpec{ProjectID: 1, Search: `getUser\((\d+)\)`, Replace: "fetchUser(${1}, opts)", UseRegex: true, CaseSensitive: true}
+	preview, err := engine.PreviewReplace(ctx, spec)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.FileCount != 2 || preview.MatchCount != 3 {
+		t.Fatalf("preview = %d files, %d matches", preview.FileCount, preview.MatchCount)
+	}
+	second := preview.Files[0].Matches[1]
+	if second.Line != 2 || second.Column != 5 || second.Before != "log(getUser(2))" || second.After != "log(fetchUser(2, opts))" {
+		t.Fatalf("second match = %+v", second)
+	}
+
+	// Apply only the second match in a.js; b.js is left alone
+	selections := []ReplaceSelection{{FileID: files[0].ID, ContentHash: preview.Files[0].ContentHash, MatchIDs: []string{second.ID}}}
+	result, err := engine.ApplyReplace(ctx, spec, selections, 7, "ada")
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if result.FilesModified != 1 || result.TotalReplaces != 1 || result.UndoToken == "" {
+		t.Fatalf("apply result = %+v", result)
+	}
+	var updated models.File
+	db.First(&updated, files[0].ID)
+	if want := "getUser(1)\nlog(fetchUser(2, opts))\ngetUserName()\n"; updated.Content != want {
+		t.Fatalf("content = %q, want %q", updated.Content, want)
+	}
+
+	// The preview is stale now, so applying it again conflicts
+	if _, err := engine.ApplyReplace(ctx, spec, selections, 7, "ada"); !errors.Is(err, ErrReplaceConflict) {
+		t.Fatalf("stale apply err = %v", err)
+	}
+
+	if _, err := engine.UndoReplace(ctx, 1, result.UndoToken, false, 7, "ada"); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	db.First(&updated, files[0].ID)
+	if updated.Content != original {
+		t.Fatalf("content after undo = %q", updated.Content)
+	}
+	if _, err := engine.UndoReplace(ctx, 1, result.UndoToken, false, 7, "ada"); !errors.Is(err, ErrAlreadyUndone) {
+		t.Fatalf("second undo err = %v", err)
+	}
+	if _, err := engine.UndoReplace(ctx, 2, result.UndoToken, false, 7, "ada"); !errors.Is(err, ErrUndoNotFound) {
+		t.Fatalf("undo in other project err = %v", err)
+	}
+}
+
+func TestUndoReplaceConflictsWithLaterEdits(t *testing.T) {
+	engine, db := newReplaceTestEngine(t)
+	ctx := context.Background()
+
+	file := models.File{ProjectID: 1, Path: "a.txt", Name: "a.txt", Type: "file", MimeType: "text/plain", Content: "cost: $5 and $5"}
+	if err := db.Create(&file).Error; err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+
+	// Literal replacements insert $1 as written
+	result, err := engine.ApplyReplace(ctx, ReplaceSpec{ProjectID: 1, Search: "$5", Replace: "$1"}, nil, 7, "ada")
+	if err != nil || result.TotalReplaces != 2 {
+		t.Fatalf("apply = %+v, %v", result, err)
+	}
+	db.First(&file, file.ID)
+	if file.Content != "cost: $1 and $1" {
+		t.Fatalf("content = %q", file.Content)
+	}
+
+	db.Model(&file).Update("content", "edited")
+	undo, err := engine.UndoReplace(ctx, 1, result.UndoToken, false, 7, "ada")
+	if !errors.Is(err, ErrReplaceConflict) || len(undo.Conflicts) != 1 || undo.Conflicts[0].Reason != "changed" {
+		t.Fatalf("undo = %+v, %v", undo, err)
+	}
+	if _, err := engine.UndoReplace(ctx, 1, result.UndoToken, true, 7, "ada"); err != nil {
+		t.Fatalf("forced undo: %v", err)
+	}
+	db.First(&file, file.ID)
+	if file.Content != "cost: $5 and $5" {
+		t.Fatalf("content after forced undo = %q", file.Content)
+	}
+	var kept int64
+	db.Model(&models.FileVersion{}).Where("file_id = ? AND content = ?", file.ID, "edited").Count(&kept)
+	if kept != 1 {
+		t.Fatalf("edited content kept in %d versions, want 1", kept)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"apex-build/internal/symbols"
 	"apex-build/pkg/models"
 
 	"gorm.io/gorm"
@@ -30,7 +31,11 @@ func escapeLikePattern(input string) string {
 type SearchEngine struct {
 	db    *gorm.DB
 	cache *SearchCache
-	mu    sync.RWMutex
+	index *Indexer
+	// symbols answers symbol searches for files it has parsed
+	symbols  *symbols.Service
+	versions VersionRecorder
+	mu       sync.RWMutex
 }
 
 // SearchCache provides fast caching for search results
@@ -103,6 +108,7 @@ type SearchStats struct {
 	BytesSearched   int64                 `json:"bytes_searched"`
 	MatchesByType   map[string]int        `json:"matches_by_type"`
 	TopFiles        []string              `json:"top_files"`
+	Indexed         bool                  `json:"indexed"` // candidates came from the full-text index
 }
 
 // SymbolResult represents a code symbol (function, class, variable)
@@ -112,8 +118,11 @@ type SymbolResult struct {
 	FileID     uint   `json:"file_id"`
 	FilePath   string `json:"file_path"`
 	LineNumber int    `json:"line_number"`
+	Column     int    `json:"column,omitempty"` // of the name; set for parsed files
+	EndLine    int    `json:"end_line,omitempty"`
 	Signature  string `json:"signature,omitempty"`
 	Container  string `json:"container,omitempty"` // Parent class/module
+	Exported   bool   `json:"exported,omitempty"`
 }
 
 // NewSearchEngine creates a new search engine instance
@@ -127,6 +136,18 @@ func NewSearchEngine(db *gorm.DB) *SearchEngine {
 	}
 }
 
+// SetIndexer narrows content searches in large projects through the
+// full-text index and ranks its matches.
+func (e *SearchEngine) SetIndexer(index *Indexer) {
+	e.index = index
+}
+
+// SetSymbolIndex answers symbol searches from parsed declarations for the
+// files the index covers. Other files are still matched by pattern.
+func (e *SearchEngine) SetSymbolIndex(index *symbols.Service) {
+	e.symbols = index
+}
+
 // Search performs a comprehensive code search
 func (e *SearchEngine) Search(ctx context.Context, query *SearchQuery) (*SearchResults, error) {
 	start := time.Now()
@@ -150,7 +171,7 @@ func (e *SearchEngine) Search(ctx context.Context, query *SearchQuery) (*SearchR
 	}
 
 	// Fetch files to search
-	files, err := e.getFilesToSearch(ctx, query)
+	files, ranks, err := e.getIndexedFilesToSearch(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch files: %w", err)
 	}
@@ -160,6 +181,7 @@ func (e *SearchEngine) Search(ctx context.Context, query *SearchQuery) (*SearchR
 		Files:   make([]*FileResult, 0),
 		Stats: &SearchStats{
 			MatchesByType: make(map[string]int),
+			Indexed:       ranks != nil,
 		},
 	}
 
@@ -182,6 +204,7 @@ func (e *SearchEngine) Search(ctx context.Context, query *SearchQuery) (*SearchR
 			defer func() { <-semaphore }()
 
 			if fileResult := e.searchFile(ctx, query, &f, pattern); fileResult != nil {
+				fileResult.Score += ranks[f.ID] * indexRankWeight
 				resultsChan <- fileResult
 			}
 
@@ -256,6 +279,16 @@ func (e *SearchEngine) SearchSymbols(ctx context.Context, query *SearchQuery) ([
 	var symbols []*SymbolResult
 	var mu sync.Mutex
 
+	// Files the symbol index covers are answered from it; the patterns
+	// below handle the rest
+	var covered map[uint]bool
+	if e.symbols != nil && query.ProjectID > 0 {
+		indexed, indexedFiles, err := e.indexedSymbols(ctx, query, files)
+		if err == nil {
+			symbols, covered = indexed, indexedFiles
+		}
+	}
+
 	// Symbol patterns for different languages
 	patterns := map[string][]*regexp.Regexp{
 		".go": {
@@ -294,6 +327,9 @@ func (e *SearchEngine) SearchSymbols(ctx context.Context, query *SearchQuery) ([
 	queryLower := strings.ToLower(query.Query)
 
 	for _, file := range files {
+		if covered[file.ID] {
+			continue
+		}
 		ext := e.getFileExtension(file.Path)
 		filePatterns, ok := patterns[ext]
 		if !ok {
@@ -331,8 +367,8 @@ func (e *SearchEngine) SearchSymbols(ctx context.Context, query *SearchQuery) ([
 		}
 	}
 
-	// Sort by name relevance
-	sort.Slice(symbols, func(i, j int) bool {
+	// Sort by name relevance, keeping the index's order within a score
+	sort.SliceStable(symbols, func(i, j int) bool {
 		iScore := e.symbolRelevance(symbols[i].Name, query.Query)
 		jScore := e.symbolRelevance(symbols[j].Name, query.Query)
 		return iScore > jScore
@@ -345,6 +381,52 @@ func (e *SearchEngine) SearchSymbols(ctx context.Context, query *SearchQuery) ([
 	return symbols, nil
 }
 
+// indexedSymbols searches the symbol index within files, returning the
+// matches and the files the index covers
+func (e *SearchEngine) indexedSymbols(ctx context.Context, query *SearchQuery, files []models.File) ([]*SymbolResult, map[uint]bool, error) {
+	covered, err := e.symbols.CoveredFiles(ctx, query.ProjectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	inScope := make(map[uint]bool, len(covered))
+	for _, file := range files {
+		if covered[file.ID] {
+			inScope[file.ID] = true
+		}
+	}
+	if len(inScope) == 0 {
+		return nil, inScope, nil
+	}
+
+	found, err := e.symbols.Search(ctx, symbols.Query{
+		ProjectID: query.ProjectID,
+		Text:      query.Query,
+		Limit:     max(query.MaxResults, 1) * 4, // some may fall outside the query's files
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make([]*SymbolResult, 0, len(found))
+	for _, sym := range found {
+		if !inScope[sym.FileID] {
+			continue
+		}
+		results = append(results, &SymbolResult{
+			Name:       sym.Name,
+			Kind:       sym.Kind,
+			FileID:     sym.FileID,
+			FilePath:   sym.FilePath,
+			LineNumber: sym.Line,
+			Column:     sym.Column,
+			EndLine:    sym.EndLine,
+			Signature:  sym.Signature,
+			Container:  sym.Container,
+			Exported:   sym.Exported,
+		})
+	}
+	return results, inScope, nil
+}
+
 // SearchAndReplace performs search and replace across files
 func (e *SearchEngine) SearchAndReplace(ctx context.Context, projectID uint, search, replace string, options *SearchQuery) (*ReplaceResults, error) {
 	options.Query = search
@@ -482,13 +564,56 @@ type ModifiedFile struct {
 
 // Helper methods
 
+// rankedFile is a file with its full-text rank against the query
+type rankedFile struct {
+	models.File
+	SearchRank float64
+}
+
+// getIndexedFilesToSearch narrows the files through the full-text index
+// when the indexer accepts the query. ranks is nil when every file was read.
+// Files the index cannot vouch for yet, being new, changed or too large,
+// are always included.
+func (e *SearchEngine) getIndexedFilesToSearch(ctx context.Context, query *SearchQuery) ([]models.File, map[uint]float64, error) {
+	tsQuery := e.index.candidateFilter(ctx, query)
+	if tsQuery == "" {
+		files, err := e.getFilesToSearch(ctx, query)
+		return files, nil, err
+	}
+
+	var ranked []rankedFile
+	db := e.filesToSearch(ctx, query).
+		Joins("LEFT JOIN file_search_index i ON i.file_id = files.id").
+		Where(strings.ReplaceAll(pendingCondition, "f.", "files.")+" OR files.size >= ? OR i.search_vector @@ to_tsquery('simple', ?)", maxIndexedBytes, tsQuery).
+		Select("files.*, COALESCE(ts_rank("+rankWeights+", i.search_vector, to_tsquery('simple', ?)), 0) AS search_rank", tsQuery)
+	if err := db.Find(&ranked).Error; err != nil {
+		return nil, nil, err
+	}
+
+	files := make([]models.File, len(ranked))
+	ranks := make(map[uint]float64, len(ranked))
+	for i, r := range ranked {
+		files[i] = r.File
+		ranks[r.ID] = r.SearchRank
+	}
+	return files, ranks, nil
+}
+
 func (e *SearchEngine) getFilesToSearch(ctx context.Context, query *SearchQuery) ([]models.File, error) {
 	var files []models.File
+	if err := e.filesToSearch(ctx, query).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
 
-	db := e.db.WithContext(ctx)
+// filesToSearch scopes the files table to the query's project and filters.
+// Columns are qualified so the index can be joined in.
+func (e *SearchEngine) filesToSearch(ctx context.Context, query *SearchQuery) *gorm.DB {
+	db := e.db.WithContext(ctx).Model(&models.File{})
 
 	if query.ProjectID > 0 {
-		db = db.Where("project_id = ?", query.ProjectID)
+		db = db.Where("files.project_id = ?", query.ProjectID)
 	}
 
 	// Filter by file types
@@ -496,7 +621,7 @@ func (e *SearchEngine) getFilesToSearch(ctx context.Context, query *SearchQuery)
 		var conditions []string
 		var args []interface{}
 		for _, ext := range query.FileTypes {
-			conditions = append(conditions, "path LIKE ? ESCAPE '\\'")
+			conditions = append(conditions, "files.path LIKE ? ESCAPE '\\'")
 			args = append(args, "%"+escapeLikePattern(ext))
 		}
 		db = db.Where(strings.Join(conditions, " OR "), args...)
@@ -507,7 +632,7 @@ func (e *SearchEngine) getFilesToSearch(ctx context.Context, query *SearchQuery)
 		var conditions []string
 		var args []interface{}
 		for _, path := range query.Paths {
-			conditions = append(conditions, "path LIKE ? ESCAPE '\\'")
+			conditions = append(conditions, "files.path LIKE ? ESCAPE '\\'")
 			args = append(args, escapeLikePattern(path)+"%")
 		}
 		db = db.Where(strings.Join(conditions, " OR "), args...)
@@ -516,19 +641,15 @@ func (e *SearchEngine) getFilesToSearch(ctx context.Context, query *SearchQuery)
 	// Exclude paths
 	if len(query.ExcludePaths) > 0 {
 		for _, path := range query.ExcludePaths {
-			db = db.Where("path NOT LIKE ? ESCAPE '\\'", escapeLikePattern(path)+"%")
+			db = db.Where("files.path NOT LIKE ? ESCAPE '\\'", escapeLikePattern(path)+"%")
 		}
 	}
 
 	// Exclude binary files and large files
-	db = db.Where("mime_type LIKE ?", "text/%")
-	db = db.Where("size < ?", 1024*1024) // Max 1MB files
+	db = db.Where("files.mime_type LIKE ?", "text/%")
+	db = db.Where("files.size < ?", 1024*1024) // Max 1MB files
 
-	if err := db.Find(&files).Error; err != nil {
-		return nil, err
-	}
-
-	return files, nil
+	return db
 }
 
 func (e *SearchEngine) buildSearchPattern(query *SearchQuery) (*regexp.Regexp, error) {
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxWorkspaceProjects caps how many projects one workspace search
+	// covers; the most recently updated are searched first.
+	MaxWorkspaceProjects = 200
+
+	// workspaceFilesPerProject caps the files listed per project
+	workspaceFilesPerProject = 20
+
+	// workspaceSearchBudget bounds a workspace search; projects not reached
+	// in time are left out and the results marked truncated
+	workspaceSearchBudget = 10 * time.Second
+)
+
+// WorkspaceProject is a project a workspace search may read.
+type WorkspaceProject struct {
+	ID             uint
+	Name           string
+	OrganizationID *uint
+}
+
+// ProjectResults are a workspace search's matches in one project.
+type ProjectResults struct {
+	ProjectID      uint          `json:"project_id"`
+	ProjectName    string        `json:"project_name"`
+	OrganizationID *uint         `json:"organization_id,omitempty"`
+	TotalMatches   int           `json:"total_matches"`
+	FileMatches    int           `json:"file_matches"`
+	Truncated      bool          `json:"truncated"`
+	Files          []*FileResult `json:"files"`
+}
+
+// WorkspaceResults groups matches across projects, projects with the most
+// matches first.
+type WorkspaceResults struct {
+	Query            string            `json:"query"`
+	TotalMatches     int               `json:"total_matches"`
+	FileMatches      int               `json:"file_matches"`
+	ProjectsSearched int               `json:"projects_searched"`
+	ProjectsMatched  int               `json:"projects_matched"`
+	Projects         []*ProjectResults `json:"projects"`
+	// Truncated is set when some projects were not searched
+	Truncated bool          `json:"truncated"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// SearchWorkspace runs query in each of projects, which the caller has
+// already filtered to those the user may read, and groups the results by
+// project. query.ProjectID is ignored.
+func (e *SearchEngine) SearchWorkspace(ctx context.Context, projects []WorkspaceProject, query SearchQuery) (*WorkspaceResults, error) {
+	start := time.Now()
+	results := &WorkspaceResults{Query: query.Query, Projects: make([]*ProjectResults, 0)}
+	if len(projects) > MaxWorkspaceProjects {
+		projects = projects[:MaxWorkspaceProjects]
+		results.Truncated = true
+	}
+
+	perProject := query.MaxResults
+	if perProject <= 0 || perProject > workspaceFilesPerProject {
+		perProject = workspaceFilesPerProject
+	}
+
+	for _, project := range projects {
+		if ctx.Err() != nil || time.Since(start) > workspaceSearchBudget {
+			results.Truncated = true
+			break
+		}
+		projectQuery := query
+		projectQuery.ProjectID = project.ID
+		projectQuery.MaxResults = perProject
+		projectQuery.Offset = 0
+
+		found, err := e.Search(ctx, &projectQuery)
+		if err != nil {
+			return nil, err
+		}
+		results.ProjectsSearched++
+		if found.FileMatches == 0 {
+			continue
+		}
+		results.Projects = append(results.Projects, &ProjectResults{
+			ProjectID:      project.ID,
+			ProjectName:    project.Name,
+			OrganizationID: project.OrganizationID,
+			TotalMatches:   found.TotalMatches,
+			FileMatches:    found.FileMatches,
+			Truncated:      found.Truncated,
+			Files:          found.Files,
+		})
+		results.TotalMatches += found.TotalMatches
+		results.FileMatches += found.FileMatches
+	}
+
+	sort.SliceStable(results.Projects, func(i, j int) bool {
+		return results.Projects[i].TotalMatches > results.Projects[j].TotalMatches
+	})
+	results.ProjectsMatched = len(results.Projects)
+	results.Duration = time.Since(start)
+	return results, nil
+}
@@ -0,0 +1,338 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"golang.org/x/oauth2/google"
+)
+
+// KMS providers that can wrap organization data keys
+const (
+	KMSProviderLocal = "local"   // Wrapped with a key derived from SECRETS_MASTER_KEY
+	KMSProviderAWS   = "aws-kms" // AWS KMS key ARN or alias ARN
+	KMSProviderGCP   = "gcp-kms" // GCP KMS key resource name
+)
+
+// ErrKMSUnavailable wraps failures to reach or use a KMS key
+var ErrKMSUnavailable = errors.New("key management service unavailable")
+
+// KeyWrapper wraps and unwraps data encryption keys with a key encryption
+// key held outside the database. The context is bound to the wrapped key
+// and must match on unwrap.
+type KeyWrapper interface {
+	Wrap(ctx context.Context, dataKey []byte, keyContext map[string]string) ([]byte, error)
+	Unwrap(ctx context.Context, wrapped []byte, keyContext map[string]string) ([]byte, error)
+}
+
+// KeyWrapperFactory returns the wrapper for a provider and key ID
+type KeyWrapperFactory func(ctx context.Context, provider, keyID string) (KeyWrapper, error)
+
+// ValidateKMSKey checks a provider and key ID before they are stored
+func ValidateKMSKey(provider, keyID string) error {
+	switch provider {
+	case KMSProviderLocal:
+		if keyID != "" {
+			return fmt.Errorf("%w: local keys take no kms_key_id", ErrInvalidKey)
+		}
+	case KMSProviderAWS:
+		if !strings.HasPrefix(keyID, "arn:aws:kms:") && !strings.HasPrefix(keyID, "arn:aws-us-gov:kms:") {
+			return fmt.Errorf("%w: aws-kms keys must be a key or alias ARN", ErrInvalidKey)
+		}
+	case KMSProviderGCP:
+		parts := strings.Split(keyID, "/")
+		if len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "keyRings" || parts[6] != "cryptoKeys" {
+			return fmt.Errorf("%w: gcp-kms keys must be projects/*/locations/*/keyRings/*/cryptoKeys/*", ErrInvalidKey)
+		}
+	default:
+		return fmt.Errorf("%w: provider must be local, aws-kms or gcp-kms", ErrInvalidKey)
+	}
+	return nil
+}
+
+// KeyWrapper returns the default wrapper for a provider: the master key
+// for local keys, or the AWS/GCP KMS APIs with ambient credentials
+func (sm *SecretsManager) KeyWrapper(ctx context.Context, provider, keyID string) (KeyWrapper, error) {
+	if err := ValidateKMSKey(provider, keyID); err != nil {
+		return nil, err
+	}
+	switch provider {
+	case KMSProviderAWS:
+		return newAWSKMS(ctx, keyID)
+	case KMSProviderGCP:
+		return newGCPKMS(ctx, keyID)
+	default:
+		wrapper := localWrapper{key: localKEK(sm.masterKey)}
+		if len(sm.previousKey) > 0 {
+			wrapper.previous = localKEK(sm.previousKey)
+		}
+		return wrapper, nil
+	}
+}
+
+func localKEK(masterKey []byte) []byte {
+	kek := sha256.Sum256(append([]byte("apex-build/org-kek/v1:"), masterKey...))
+	return kek[:]
+}
+
+// encodeKeyContext serializes a key context deterministically
+func encodeKeyContext(keyContext map[string]string) []byte {
+	names := make([]string, 0, len(keyContext))
+	for name := range keyContext {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(keyContext[name])
+		buf.WriteByte(';')
+	}
+	return buf.Bytes()
+}
+
+// localWrapper wraps with AES-256-GCM under a key derived from the master
+// key. During a master key rotation, keys wrapped under the previous master
+// key still unwrap.
+type localWrapper struct {
+	key      []byte
+	previous []byte
+}
+
+func (w localWrapper) Wrap(_ context.Context, dataKey []byte, keyContext map[string]string) ([]byte, error) {
+	gcm, err := newGCM(w.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, dataKey, encodeKeyContext(keyContext)), nil
+}
+
+func (w localWrapper) Unwrap(_ context.Context, wrapped []byte, keyContext map[string]string) ([]byte, error) {
+	dataKey, err := unwrapLocal(w.key, wrapped, keyContext)
+	if errors.Is(err, ErrDecryptionFailed) && len(w.previous) > 0 {
+		return unwrapLocal(w.previous, wrapped, keyContext)
+	}
+	return dataKey, err
+}
+
+func unwrapLocal(kek, wrapped []byte, keyContext map[string]string) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	dataKey, err := gcm.Open(nil, nonce, ciphertext, encodeKeyContext(keyContext))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+var kmsHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// awsKMS calls the AWS KMS Encrypt and Decrypt APIs
+type awsKMS struct {
+	keyID    string
+	region   string
+	endpoint string
+	creds    aws.CredentialsProvider
+	signer   *v4.Signer
+}
+
+func newAWSKMS(ctx context.Context, keyID string) (*awsKMS, error) {
+	// arn:aws:kms:<region>:<account>:key/<id>
+	parts := strings.SplitN(keyID, ":", 6)
+	region := ""
+	if len(parts) == 6 {
+		region = parts[3]
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	endpoint := os.Getenv("AWS_KMS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com/"
+	}
+	return &awsKMS{
+		keyID:    keyID,
+		region:   region,
+		endpoint: endpoint,
+		creds:    cfg.Credentials,
+		signer:   v4.NewSigner(),
+	}, nil
+}
+
+func (k *awsKMS) call(ctx context.Context, action string, input, output any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	creds, err := k.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := k.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), "kms", k.region, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	return doKMSRequest(req, output)
+}
+
+func (k *awsKMS) Wrap(ctx context.Context, dataKey []byte, keyContext map[string]string) ([]byte, error) {
+	var out struct {
+		CiphertextBlob []byte
+	}
+	err := k.call(ctx, "Encrypt", map[string]any{
+		"KeyId":             k.keyID,
+		"Plaintext":         dataKey,
+		"EncryptionContext": keyContext,
+	}, &out)
+	return out.CiphertextBlob, err
+}
+
+func (k *awsKMS) Unwrap(ctx context.Context, wrapped []byte, keyContext map[string]string) ([]byte, error) {
+	var out struct {
+		Plaintext []byte
+	}
+	err := k.call(ctx, "Decrypt", map[string]any{
+		"KeyId":             k.keyID,
+		"CiphertextBlob":    wrapped,
+		"EncryptionContext": keyContext,
+	}, &out)
+	return out.Plaintext, err
+}
+
+// gcpKMS calls the Cloud KMS encrypt and decrypt methods. The key context
+// is bound as additional authenticated data.
+type gcpKMS struct {
+	name     string
+	endpoint string
+	client   *http.Client
+}
+
+func newGCPKMS(ctx context.Context, name string) (*gcpKMS, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloudkms")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	client.Timeout = kmsHTTPClient.Timeout
+	endpoint := os.Getenv("GCP_KMS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	return &gcpKMS{name: name, endpoint: strings.TrimRight(endpoint, "/"), client: client}, nil
+}
+
+func (k *gcpKMS) call(ctx context.Context, method string, input, output any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint+"/v1/"+k.name+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	return decodeKMSResponse(resp, output)
+}
+
+func (k *gcpKMS) Wrap(ctx context.Context, dataKey []byte, keyContext map[string]string) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := k.call(ctx, "encrypt", map[string]any{
+		"plaintext":                   dataKey,
+		"additionalAuthenticatedData": encodeKeyContext(keyContext),
+	}, &out)
+	return out.Ciphertext, err
+}
+
+func (k *gcpKMS) Unwrap(ctx context.Context, wrapped []byte, keyContext map[string]string) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	err := k.call(ctx, "decrypt", map[string]any{
+		"ciphertext":                  wrapped,
+		"additionalAuthenticatedData": encodeKeyContext(keyContext),
+	}, &out)
+	return out.Plaintext, err
+}
+
+func doKMSRequest(req *http.Request, output any) error {
+	resp, err := kmsHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	return decodeKMSResponse(resp, output)
+}
+
+func decodeKMSResponse(resp *http.Response, output any) error {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(body))
+		if len(message) > 300 {
+			message = message[:300]
+		}
+		return fmt.Errorf("%w: status %d: %s", ErrKMSUnavailable, resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(body, output); err != nil {
+		return fmt.Errorf("%w: %v", ErrKMSUnavailable, err)
+	}
+	return nil
+}
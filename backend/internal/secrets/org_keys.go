@@ -0,0 +1,573 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization data key states
+const (
+	OrgKeyActive    = "active"    // Encrypts new values
+	OrgKeyRetired   = "retired"   // Replaced by a rotation; still decrypts
+	OrgKeyDestroyed = "destroyed" // Crypto-shredded; values under it are unrecoverable
+)
+
+// orgCiphertextPrefix marks values encrypted with an organization data
+// key: apexorg:v1:<org id>:<key version>:<base64 nonce+ciphertext>
+const orgCiphertextPrefix = "apexorg:v1:"
+
+const (
+	orgMembershipTTL = time.Minute
+	kmsCallTimeout   = 15 * time.Second
+)
+
+var (
+	// ErrOrgKeyNotFound is returned when an organization has no data key
+	ErrOrgKeyNotFound = errors.New("organization encryption key not found")
+	// ErrKeyDestroyed is returned when decrypting a value whose
+	// organization key was crypto-shredded
+	ErrKeyDestroyed = errors.New("encryption key has been destroyed")
+)
+
+// OrgDataKey is a version of an organization's data encryption key, stored
+// wrapped by the organization's KMS key
+type OrgDataKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uint   `json:"organization_id" gorm:"not null;uniqueIndex:idx_org_data_key_version"`
+	Version        int    `json:"version" gorm:"not null;uniqueIndex:idx_org_data_key_version"`
+	Provider       string `json:"provider" gorm:"not null;size:20"`
+	KMSKeyID       string `json:"kms_key_id,omitempty" gorm:"size:500"`
+	WrappedKey     string `json:"-" gorm:"type:text"` // Emptied when destroyed
+	Fingerprint    string `json:"fingerprint" gorm:"size:64"`
+	Status         string `json:"status" gorm:"not null;size:20;index"`
+
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+	DestroyedAt *time.Time `json:"destroyed_at,omitempty"`
+	CreatedBy   uint       `json:"created_by"`
+}
+
+// TableName specifies the table name for OrgDataKey
+func (OrgDataKey) TableName() string {
+	return "org_data_keys"
+}
+
+func (k *OrgDataKey) keyContext() map[string]string {
+	return map[string]string{
+		"apex:organization_id": strconv.FormatUint(uint64(k.OrganizationID), 10),
+		"apex:key_version":     strconv.Itoa(k.Version),
+	}
+}
+
+func (k *OrgDataKey) ciphertextPrefix() string {
+	return fmt.Sprintf("%s%d:%d:", orgCiphertextPrefix, k.OrganizationID, k.Version)
+}
+
+// EncryptedColumn names a table column holding SecretsManager ciphertext,
+// so rotation can re-encrypt it. Fingerprint is empty for tables that don't
+// record the key fingerprint.
+type EncryptedColumn struct {
+	Table       string
+	UserID      string
+	Value       string
+	Salt        string
+	Fingerprint string
+}
+
+// DefaultEncryptedColumns are re-encrypted when an organization or the
+// master key rotates: project and user secrets (which also hold git, MCP and
+// mobile signing credentials), BYOK provider keys, project service tokens and
+// managed database passwords. Every table written with
+// SecretsManager.Encrypt must be listed here.
+var DefaultEncryptedColumns = []EncryptedColumn{
+	{Table: "secrets", UserID: "user_id", Value: "encrypted_value", Salt: "salt", Fingerprint: "key_fingerprint"},
+	{Table: "user_api_keys", UserID: "user_id", Value: "encrypted_key", Salt: "key_salt", Fingerprint: "key_fingerprint"},
+	{Table: "project_service_tokens", UserID: "user_id", Value: "encrypted_value", Salt: "salt", Fingerprint: "key_fingerprint"},
+	{Table: "managed_databases", UserID: "user_id", Value: "password", Salt: "salt"},
+}
+
+// updates returns the column values for a re-encrypted row
+func (c EncryptedColumn) updates(value, salt, fingerprint string) map[string]interface{} {
+	updates := map[string]interface{}{c.Value: value, c.Salt: salt}
+	if c.Fingerprint != "" {
+		updates[c.Fingerprint] = fingerprint
+	}
+	return updates
+}
+
+// RotationSummary reports the re-encryption done by a rotation
+type RotationSummary struct {
+	Reencrypted int      `json:"reencrypted"`
+	Failed      int      `json:"failed"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// OrgKeyring holds per-organization data keys. Once an organization has a
+// key, values its active members encrypt use it instead of the master key
+// (a member of several such organizations uses the one with the lowest ID).
+// Data keys are wrapped by the organization's KMS key and kept unwrapped
+// only in memory.
+type OrgKeyring struct {
+	db       *gorm.DB
+	sm       *SecretsManager
+	wrappers KeyWrapperFactory
+	columns  []EncryptedColumn
+
+	mu       sync.RWMutex
+	dataKeys map[orgKeyVersion][]byte
+	members  map[uint]orgMembership // UserID -> organization with a key
+}
+
+type orgKeyVersion struct {
+	orgID   uint
+	version int
+}
+
+type orgMembership struct {
+	orgID   uint
+	expires time.Time
+}
+
+// NewOrgKeyring creates a keyring. Attach it with SecretsManager.SetOrgKeyring.
+func NewOrgKeyring(db *gorm.DB, sm *SecretsManager) *OrgKeyring {
+	return &OrgKeyring{
+		db:       db,
+		sm:       sm,
+		wrappers: sm.KeyWrapper,
+		columns:  DefaultEncryptedColumns,
+		dataKeys: make(map[orgKeyVersion][]byte),
+		members:  make(map[uint]orgMembership),
+	}
+}
+
+// SetKeyWrapperFactory replaces how KMS keys are reached
+func (k *OrgKeyring) SetKeyWrapperFactory(factory KeyWrapperFactory) {
+	k.wrappers = factory
+}
+
+// SetOrgKeyring enables per-organization data keys
+func (sm *SecretsManager) SetOrgKeyring(keyring *OrgKeyring) {
+	sm.orgKeys = keyring
+}
+
+// IsOrgCiphertext reports whether a value was encrypted with an
+// organization data key rather than the master key
+func IsOrgCiphertext(encryptedValue string) bool {
+	return strings.HasPrefix(encryptedValue, orgCiphertextPrefix)
+}
+
+// organizationFor returns the organization whose key encrypts a user's
+// values, or 0 for the master key
+func (k *OrgKeyring) organizationFor(userID uint) (uint, error) {
+	k.mu.RLock()
+	membership, ok := k.members[userID]
+	k.mu.RUnlock()
+	if ok && time.Now().Before(membership.expires) {
+		return membership.orgID, nil
+	}
+
+	var orgIDs []uint
+	err := k.db.Table("organization_members").
+		Where("user_id = ? AND status = ? AND deleted_at IS NULL", userID, "active").
+		Where("organization_id IN (?)", k.db.Model(&OrgDataKey{}).Select("organization_id").Where("status = ?", OrgKeyActive)).
+		Order("organization_id").Limit(1).
+		Pluck("organization_id", &orgIDs).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve organization key: %w", err)
+	}
+	var orgID uint
+	if len(orgIDs) > 0 {
+		orgID = orgIDs[0]
+	}
+	k.mu.Lock()
+	k.members[userID] = orgMembership{orgID: orgID, expires: time.Now().Add(orgMembershipTTL)}
+	k.mu.Unlock()
+	return orgID, nil
+}
+
+// ActiveKey returns an organization's current data key
+func (k *OrgKeyring) ActiveKey(orgID uint) (*OrgDataKey, error) {
+	var key OrgDataKey
+	if err := k.db.Where("organization_id = ? AND status = ?", orgID, OrgKeyActive).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrgKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Keys returns every version of an organization's data key, newest first
+func (k *OrgKeyring) Keys(orgID uint) ([]OrgDataKey, error) {
+	var keys []OrgDataKey
+	err := k.db.Where("organization_id = ?", orgID).Order("version DESC").Find(&keys).Error
+	return keys, err
+}
+
+// unwrap returns the plaintext data key for a version, asking the KMS only
+// on a cache miss
+func (k *OrgKeyring) unwrap(ctx context.Context, key *OrgDataKey) ([]byte, error) {
+	if key.Status == OrgKeyDestroyed || key.WrappedKey == "" {
+		return nil, ErrKeyDestroyed
+	}
+	ref := orgKeyVersion{orgID: key.OrganizationID, version: key.Version}
+	k.mu.RLock()
+	dataKey, ok := k.dataKeys[ref]
+	k.mu.RUnlock()
+	if ok {
+		return dataKey, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(key.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	wrapper, err := k.wrappers(ctx, key.Provider, key.KMSKeyID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err = wrapper.Unwrap(ctx, wrapped, key.keyContext())
+	if err != nil {
+		return nil, err
+	}
+	if len(dataKey) != 32 {
+		return nil, ErrInvalidKey
+	}
+	k.mu.Lock()
+	k.dataKeys[ref] = dataKey
+	k.mu.Unlock()
+	return dataKey, nil
+}
+
+func (k *OrgKeyring) keyVersion(orgID uint, version int) (*OrgDataKey, error) {
+	var key OrgDataKey
+	if err := k.db.Where("organization_id = ? AND version = ?", orgID, version).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrgKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// encrypt encrypts a value for a user with their organization's key. ok is
+// false when the user's values use the master key.
+func (k *OrgKeyring) encrypt(userID uint, value string) (encryptedValue, saltBase64, keyFingerprint string, ok bool, err error) {
+	orgID, err := k.organizationFor(userID)
+	if err != nil || orgID == 0 {
+		return "", "", "", false, err
+	}
+	key, err := k.ActiveKey(orgID)
+	if errors.Is(err, ErrOrgKeyNotFound) {
+		// The key was destroyed since membership was cached
+		k.forgetMembers()
+		return "", "", "", false, nil
+	}
+	if err != nil {
+		return "", "", "", false, err
+	}
+	encryptedValue, saltBase64, err = k.encryptWith(key, userID, value)
+	return encryptedValue, saltBase64, key.Fingerprint, err == nil, err
+}
+
+func (k *OrgKeyring) encryptWith(key *OrgDataKey, userID uint, value string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kmsCallTimeout)
+	defer cancel()
+	dataKey, err := k.unwrap(ctx, key)
+	if err != nil {
+		return "", "", err
+	}
+	salt, err := generateSalt()
+	if err != nil {
+		return "", "", err
+	}
+	saltBase64 := base64.StdEncoding.EncodeToString(salt)
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// Binding the user and salt keeps values from being swapped between rows
+	ciphertext := gcm.Seal(nonce, nonce, []byte(value), orgValueAAD(userID, saltBase64))
+	return key.ciphertextPrefix() + base64.StdEncoding.EncodeToString(ciphertext), saltBase64, nil
+}
+
+// decrypt decrypts a value encrypted with an organization data key
+func (k *OrgKeyring) decrypt(userID uint, encryptedValue, saltBase64 string) (string, error) {
+	orgID, version, payload, err := parseOrgCiphertext(encryptedValue)
+	if err != nil {
+		return "", err
+	}
+	key, err := k.keyVersion(orgID, version)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kmsCallTimeout)
+	defer cancel()
+	dataKey, err := k.unwrap(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return "", ErrDecryptionFailed
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, orgValueAAD(userID, saltBase64))
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	return string(plaintext), nil
+}
+
+func orgValueAAD(userID uint, saltBase64 string) []byte {
+	return []byte(fmt.Sprintf("user:%d:salt:%s", userID, saltBase64))
+}
+
+func parseOrgCiphertext(encryptedValue string) (uint, int, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(encryptedValue, orgCiphertextPrefix), ":", 3)
+	if len(parts) != 3 {
+		return 0, 0, "", ErrInvalidSecret
+	}
+	orgID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, 0, "", ErrInvalidSecret
+	}
+	version, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, "", ErrInvalidSecret
+	}
+	return uint(orgID), version, parts[2], nil
+}
+
+// Rotate creates a new active data key for an organization, wrapped by the
+// given KMS key (or the current one when provider is empty), then
+// re-encrypts the organization's values under it. The first rotation
+// enables organization keys and moves members' master-key values over.
+// Retired versions stay available for values that could not be moved.
+func (k *OrgKeyring) Rotate(ctx context.Context, orgID, createdBy uint, provider, kmsKeyID string) (*OrgDataKey, *RotationSummary, error) {
+	current, err := k.ActiveKey(orgID)
+	if err != nil && !errors.Is(err, ErrOrgKeyNotFound) {
+		return nil, nil, err
+	}
+	if provider == "" {
+		provider, kmsKeyID = KMSProviderLocal, ""
+		if current != nil {
+			provider, kmsKeyID = current.Provider, current.KMSKeyID
+		}
+	}
+	if err := ValidateKMSKey(provider, kmsKeyID); err != nil {
+		return nil, nil, err
+	}
+	wrapper, err := k.wrappers(ctx, provider, kmsKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	digest := sha256.Sum256(dataKey)
+	key := &OrgDataKey{
+		OrganizationID: orgID,
+		Version:        1,
+		Provider:       provider,
+		KMSKeyID:       kmsKeyID,
+		Status:         OrgKeyActive,
+		CreatedBy:      createdBy,
+	}
+
+	err = k.db.Transaction(func(tx *gorm.DB) error {
+		var latest OrgDataKey
+		if err := tx.Where("organization_id = ?", orgID).Order("version DESC").First(&latest).Error; err == nil {
+			key.Version = latest.Version + 1
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		key.Fingerprint = fmt.Sprintf("org:%d:v%d:%s", orgID, key.Version, base64.RawURLEncoding.EncodeToString(digest[:8]))
+
+		wrapped, err := wrapper.Wrap(ctx, dataKey, key.keyContext())
+		if err != nil {
+			return err
+		}
+		// Unwrapping proves the platform can still read values it writes
+		if check, err := wrapper.Unwrap(ctx, wrapped, key.keyContext()); err != nil || string(check) != string(dataKey) {
+			return fmt.Errorf("%w: wrapped key did not round-trip: %v", ErrKMSUnavailable, err)
+		}
+		key.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+
+		now := time.Now()
+		if err := tx.Model(&OrgDataKey{}).
+			Where("organization_id = ? AND status = ?", orgID, OrgKeyActive).
+			Updates(map[string]interface{}{"status": OrgKeyRetired, "retired_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k.mu.Lock()
+	k.dataKeys[orgKeyVersion{orgID: orgID, version: key.Version}] = dataKey
+	k.members = make(map[uint]orgMembership)
+	k.mu.Unlock()
+
+	summary := k.reencrypt(key)
+	return key, summary, nil
+}
+
+// reencrypt moves an organization's values to its active key: values under
+// its older versions, and members' values still under the master key
+func (k *OrgKeyring) reencrypt(key *OrgDataKey) *RotationSummary {
+	summary := &RotationSummary{}
+	orgPrefix := fmt.Sprintf("%s%d:", orgCiphertextPrefix, key.OrganizationID)
+	members := k.db.Table("organization_members").Select("user_id").
+		Where("organization_id = ? AND status = ? AND deleted_at IS NULL", key.OrganizationID, "active")
+
+	for _, column := range k.columns {
+		if !k.db.Migrator().HasTable(column.Table) {
+			continue
+		}
+		var rows []struct {
+			ID     uint
+			UserID uint
+			Value  string
+			Salt   string
+		}
+		err := k.db.Table(column.Table).
+			Select(fmt.Sprintf("id, %s AS user_id, %s AS value, %s AS salt", column.UserID, column.Value, column.Salt)).
+			Where(fmt.Sprintf("(%s LIKE ? AND %s NOT LIKE ?) OR (%s NOT LIKE ? AND %s IN (?))",
+				column.Value, column.Value, column.Value, column.UserID),
+				orgPrefix+"%", key.ciphertextPrefix()+"%", orgCiphertextPrefix+"%", members).
+			Scan(&rows).Error
+		if err != nil {
+			summary.Failed++
+			summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", column.Table, err))
+			continue
+		}
+
+		for _, row := range rows {
+			plaintext, err := k.sm.Decrypt(row.UserID, row.Value, row.Salt)
+			if err != nil {
+				summary.Failed++
+				summary.Errors = append(summary.Errors, fmt.Sprintf("%s %d: decrypt failed: %v", column.Table, row.ID, err))
+				continue
+			}
+			encrypted, salt, err := k.encryptWith(key, row.UserID, plaintext)
+			if err != nil {
+				summary.Failed++
+				summary.Errors = append(summary.Errors, fmt.Sprintf("%s %d: encrypt failed: %v", column.Table, row.ID, err))
+				continue
+			}
+			// The value check skips rows rewritten while rotation ran
+			if err := k.db.Table(column.Table).
+				Where(fmt.Sprintf("id = ? AND %s = ?", column.Value), row.ID, row.Value).
+				Updates(column.updates(encrypted, salt, key.Fingerprint)).Error; err != nil {
+				summary.Failed++
+				summary.Errors = append(summary.Errors, fmt.Sprintf("%s %d: update failed: %v", column.Table, row.ID, err))
+				continue
+			}
+			summary.Reencrypted++
+		}
+	}
+	return summary
+}
+
+// Shred destroys every version of an organization's data key. Values
+// encrypted under them can no longer be decrypted by anyone.
+func (k *OrgKeyring) Shred(orgID uint) (int64, error) {
+	result := k.db.Model(&OrgDataKey{}).
+		Where("organization_id = ? AND status <> ?", orgID, OrgKeyDestroyed).
+		Updates(map[string]interface{}{
+			"status":       OrgKeyDestroyed,
+			"wrapped_key":  "",
+			"destroyed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	k.mu.Lock()
+	for ref, dataKey := range k.dataKeys {
+		if ref.orgID == orgID {
+			for i := range dataKey {
+				dataKey[i] = 0
+			}
+			delete(k.dataKeys, ref)
+		}
+	}
+	k.members = make(map[uint]orgMembership)
+	k.mu.Unlock()
+	return result.RowsAffected, nil
+}
+
+func (k *OrgKeyring) forgetMembers() {
+	k.mu.Lock()
+	k.members = make(map[uint]orgMembership)
+	k.mu.Unlock()
+}
+
+// RewrapLocalOrgKeys re-wraps organization data keys that use the local
+// provider from an old master key to a new one, for master key rotation
+func RewrapLocalOrgKeys(db *gorm.DB, oldManager, newManager *SecretsManager) (int, error) {
+	ctx := context.Background()
+	oldWrapper, _ := oldManager.KeyWrapper(ctx, KMSProviderLocal, "")
+	newWrapper, _ := newManager.KeyWrapper(ctx, KMSProviderLocal, "")
+	return rewrapLocalOrgKeys(ctx, db, oldWrapper, newWrapper)
+}
+
+func rewrapLocalOrgKeys(ctx context.Context, db *gorm.DB, from, to KeyWrapper) (int, error) {
+	if !db.Migrator().HasTable(&OrgDataKey{}) {
+		return 0, nil
+	}
+	var keys []OrgDataKey
+	if err := db.Where("provider = ? AND status <> ?", KMSProviderLocal, OrgKeyDestroyed).Find(&keys).Error; err != nil {
+		return 0, err
+	}
+	for i := range keys {
+		wrapped, err := base64.StdEncoding.DecodeString(keys[i].WrappedKey)
+		if err != nil {
+			return i, fmt.Errorf("org key %d: %w", keys[i].ID, err)
+		}
+		dataKey, err := from.Unwrap(ctx, wrapped, keys[i].keyContext())
+		if err != nil {
+			return i, fmt.Errorf("org key %d: %w", keys[i].ID, err)
+		}
+		rewrapped, err := to.Wrap(ctx, dataKey, keys[i].keyContext())
+		if err != nil {
+			return i, fmt.Errorf("org key %d: %w", keys[i].ID, err)
+		}
+		if err := db.Model(&OrgDataKey{}).Where("id = ?", keys[i].ID).
+			Update("wrapped_key", base64.StdEncoding.EncodeToString(rewrapped)).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
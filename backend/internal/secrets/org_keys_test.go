@@ -0,0 +1,220 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type testOrgMember struct {
+	ID             uint `gorm:"primaryKey"`
+	OrganizationID uint
+	UserID         uint
+	Status         string
+	DeletedAt      gorm.DeletedAt
+}
+
+func (testOrgMember) TableName() string { return "organization_members" }
+
+func newOrgKeyringTest(t *testing.T) (*gorm.DB, *SecretsManager, *OrgKeyring) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&Secret{}, &OrgDataKey{}, &testOrgMember{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&testOrgMember{OrganizationID: 7, UserID: 1, Status: "active"})
+
+	key, _ := GenerateMasterKey()
+	sm, err := NewSecretsManager(key)
+	if err != nil {
+		t.Fatalf("NewSecretsManager() error = %v", err)
+	}
+	sm.iterations = 1000
+	keyring := NewOrgKeyring(db, sm)
+	sm.SetOrgKeyring(keyring)
+	return db, sm, keyring
+}
+
+func storeTestSecret(t *testing.T, db *gorm.DB, sm *SecretsManager, userID uint, value string) *Secret {
+	t.Helper()
+	encrypted, salt, fingerprint, err := sm.Encrypt(userID, value)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	secret := &Secret{UserID: userID, Name: value, EncryptedValue: encrypted, Salt: salt, KeyFingerprint: fingerprint}
+	if err := db.Create(secret).Error; err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	return secret
+}
+
+func TestOrgKeyringRotationReencryptsMemberSecrets(t *testing.T) {
+	db, sm, keyring := newOrgKeyringTest(t)
+
+	before := storeTestSecret(t, db, sm, 1, "member-token")
+	if IsOrgCiphertext(before.EncryptedValue) {
+		t.Fatal("secrets use the master key until the organization has a key")
+	}
+
+	first, summary, err := keyring.Rotate(context.Background(), 7, 1, "", "")
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if first.Version != 1 || first.Provider != KMSProviderLocal || summary.Reencrypted != 1 || summary.Failed != 0 {
+		t.Fatalf("first rotation = %+v, %+v", first, summary)
+	}
+	var moved Secret
+	db.First(&moved, before.ID)
+	if !strings.HasPrefix(moved.EncryptedValue, "apexorg:v1:7:1:") || moved.KeyFingerprint != first.Fingerprint {
+		t.Fatalf("secret not moved to the organization key: %q", moved.EncryptedValue)
+	}
+	if value, err := sm.Decrypt(1, moved.EncryptedValue, moved.Salt); err != nil || value != "member-token" {
+		t.Fatalf("Decrypt() = %q, %v", value, err)
+	}
+	if _, err := sm.Decrypt(2, moved.EncryptedValue, moved.Salt); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("another user's ID must not decrypt the value, got %v", err)
+	}
+
+	member := storeTestSecret(t, db, sm, 1, "new-member-secret")
+	outsider := storeTestSecret(t, db, sm, 2, "outsider-secret")
+	if !IsOrgCiphertext(member.EncryptedValue) || IsOrgCiphertext(outsider.EncryptedValue) {
+		t.Fatal("only members encrypt with the organization key")
+	}
+
+	second, summary, err := keyring.Rotate(context.Background(), 7, 1, "", "")
+	if err != nil {
+		t.Fatalf("second Rotate() error = %v", err)
+	}
+	if second.Version != 2 || summary.Reencrypted != 2 {
+		t.Fatalf("second rotation = %+v, %+v", second, summary)
+	}
+	keys, _ := keyring.Keys(7)
+	if len(keys) != 2 || keys[0].Status != OrgKeyActive || keys[1].Status != OrgKeyRetired {
+		t.Fatalf("key versions = %+v", keys)
+	}
+	var rotated Secret
+	db.First(&rotated, member.ID)
+	if !strings.HasPrefix(rotated.EncryptedValue, "apexorg:v1:7:2:") {
+		t.Fatalf("secret not moved to version 2: %q", rotated.EncryptedValue)
+	}
+	if valid, err := sm.ValidateKeyFingerprint(1, rotated.Salt, rotated.KeyFingerprint); err != nil || !valid {
+		t.Fatalf("ValidateKeyFingerprint() = %v, %v", valid, err)
+	}
+	var untouched Secret
+	db.First(&untouched, outsider.ID)
+	if untouched.EncryptedValue != outsider.EncryptedValue {
+		t.Fatal("non-member secrets must not be rewritten")
+	}
+}
+
+type testManagedDatabase struct {
+	ID       uint `gorm:"primaryKey"`
+	UserID   uint
+	Password string
+	Salt     string
+}
+
+func (testManagedDatabase) TableName() string { return "managed_databases" }
+
+func TestOrgKeyringRotationReencryptsManagedDatabasePasswords(t *testing.T) {
+	db, sm, keyring := newOrgKeyringTest(t)
+	if err := db.AutoMigrate(&testManagedDatabase{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	encrypted, salt, _, err := sm.Encrypt(1, "db-password")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	managed := testManagedDatabase{UserID: 1, Password: encrypted, Salt: salt}
+	db.Create(&managed)
+
+	if _, summary, err := keyring.Rotate(context.Background(), 7, 1, "", ""); err != nil || summary.Reencrypted != 1 || summary.Failed != 0 {
+		t.Fatalf("Rotate() = %+v, %v", summary, err)
+	}
+	var moved testManagedDatabase
+	db.First(&moved, managed.ID)
+	if !strings.HasPrefix(moved.Password, "apexorg:v1:7:1:") {
+		t.Fatalf("password not moved to the organization key: %q", moved.Password)
+	}
+	if value, err := sm.Decrypt(1, moved.Password, moved.Salt); err != nil || value != "db-password" {
+		t.Fatalf("Decrypt() = %q, %v", value, err)
+	}
+}
+
+func TestOrgKeyringShredDestroysValues(t *testing.T) {
+	db, sm, keyring := newOrgKeyringTest(t)
+	if _, _, err := keyring.Rotate(context.Background(), 7, 1, "", ""); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	secret := storeTestSecret(t, db, sm, 1, "doomed")
+
+	destroyed, err := keyring.Shred(7)
+	if err != nil || destroyed != 1 {
+		t.Fatalf("Shred() = %d, %v", destroyed, err)
+	}
+	if _, err := sm.Decrypt(1, secret.EncryptedValue, secret.Salt); !errors.Is(err, ErrKeyDestroyed) {
+		t.Fatalf("Decrypt() after shredding = %v, want ErrKeyDestroyed", err)
+	}
+	var key OrgDataKey
+	db.First(&key)
+	if key.WrappedKey != "" || key.DestroyedAt == nil {
+		t.Fatalf("wrapped key not erased: %+v", key)
+	}
+
+	after := storeTestSecret(t, db, sm, 1, "after")
+	if IsOrgCiphertext(after.EncryptedValue) {
+		t.Fatal("members fall back to the master key once the organization key is destroyed")
+	}
+}
+
+type recordingWrapper struct {
+	localWrapper
+	contexts []map[string]string
+}
+
+func (w *recordingWrapper) Wrap(ctx context.Context, dataKey []byte, keyContext map[string]string) ([]byte, error) {
+	w.contexts = append(w.contexts, keyContext)
+	return w.localWrapper.Wrap(ctx, dataKey, keyContext)
+}
+
+func TestOrgKeyringUsesConfiguredKMS(t *testing.T) {
+	db, sm, keyring := newOrgKeyringTest(t)
+	wrapper := &recordingWrapper{localWrapper: localWrapper{key: make([]byte, 32)}}
+	var requested []string
+	keyring.SetKeyWrapperFactory(func(_ context.Context, provider, keyID string) (KeyWrapper, error) {
+		requested = append(requested, provider+" "+keyID)
+		return wrapper, nil
+	})
+
+	arn := "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	if _, _, err := keyring.Rotate(context.Background(), 7, 1, KMSProviderAWS, "projects/p/bad"); err == nil {
+		t.Fatal("expected an invalid key ID to be rejected")
+	}
+	key, _, err := keyring.Rotate(context.Background(), 7, 1, KMSProviderAWS, arn)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if key.KMSKeyID != arn || len(wrapper.contexts) != 1 || wrapper.contexts[0]["apex:organization_id"] != "7" {
+		t.Fatalf("key = %+v, contexts = %v", key, wrapper.contexts)
+	}
+
+	// A fresh keyring must unwrap through the KMS to decrypt
+	secret := storeTestSecret(t, db, sm, 1, "kms-backed")
+	fresh := NewOrgKeyring(db, sm)
+	fresh.SetKeyWrapperFactory(keyring.wrappers)
+	sm.SetOrgKeyring(fresh)
+	requested = nil
+	if value, err := sm.Decrypt(1, secret.EncryptedValue, secret.Salt); err != nil || value != "kms-backed" {
+		t.Fatalf("Decrypt() = %q, %v", value, err)
+	}
+	if len(requested) != 1 || requested[0] != "aws-kms "+arn {
+		t.Fatalf("KMS requests = %v", requested)
+	}
+}
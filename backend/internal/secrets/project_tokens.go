@@ -0,0 +1,459 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectTokenPrefix starts every project service token
+const ProjectTokenPrefix = "apx_proj_"
+
+const (
+	// ProjectTokenRotationInterval is how long a project token lives before
+	// it is rotated automatically
+	ProjectTokenRotationInterval = 7 * 24 * time.Hour
+	// ProjectTokenGracePeriod is how long the previous value keeps working
+	// after a rotation, so runs and apps started with it can finish
+	ProjectTokenGracePeriod = 24 * time.Hour
+	// ProjectTokenUsageLimit is how many usage records are kept per token
+	ProjectTokenUsageLimit = 500
+	// ProjectTokenUsageRetention is how long usage records are kept, which
+	// also clears those of disabled tokens
+	ProjectTokenUsageRetention = 30 * 24 * time.Hour
+	// DefaultProjectTokenCheckInterval is how often due tokens are rotated
+	DefaultProjectTokenCheckInterval = 10 * time.Minute
+
+	projectTokenTouchInterval = time.Minute
+)
+
+// Environment variables given to scheduled jobs and hosted apps of a project
+// with a service token
+const (
+	ProjectTokenEnvVar  = "APEX_PROJECT_TOKEN"
+	ProjectIDEnvVar     = "APEX_PROJECT_ID"
+	ProjectAPIURLEnvVar = "APEX_API_URL"
+)
+
+// Project token scopes. Every token has all of them; they are listed so
+// callers can tell what a token may do.
+const (
+	ProjectScopeReadFiles    = "files:read"
+	ProjectScopeWriteOutputs = "outputs:write"
+)
+
+// ProjectTokenScopes are the scopes of every project token
+var ProjectTokenScopes = []string{ProjectScopeReadFiles, ProjectScopeWriteOutputs}
+
+var (
+	// ErrProjectTokenNotFound is returned when a project has no service token
+	ErrProjectTokenNotFound = errors.New("project service token is not enabled")
+	// ErrInvalidProjectToken is returned for unknown, rotated-out or
+	// malformed project tokens
+	ErrInvalidProjectToken = errors.New("invalid or expired project token")
+)
+
+// ProjectToken is a project's service token. Scheduled jobs and hosted apps
+// receive it in APEX_PROJECT_TOKEN to call back into the API for their own
+// project. Its value is encrypted like a secret so it can be injected again,
+// and looked up by hash.
+type ProjectToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint `json:"project_id" gorm:"not null;uniqueIndex"`
+	// UserID is the project owner its value is encrypted for: the one who
+	// enabled it, or the recipient of a later ownership transfer
+	UserID uint   `json:"user_id" gorm:"not null;index"`
+	Prefix string `json:"prefix" gorm:"size:32"`
+
+	TokenHash      string `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	EncryptedValue string `json:"-" gorm:"type:text;not null"`
+	Salt           string `json:"-" gorm:"not null"`
+	KeyFingerprint string `json:"-"`
+
+	// The value replaced by the last rotation, accepted until it expires
+	PreviousHash      string     `json:"-" gorm:"size:64;index"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+
+	Version        int        `json:"version"`
+	RotatedAt      time.Time  `json:"rotated_at"`
+	NextRotationAt time.Time  `json:"next_rotation_at" gorm:"index"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+// TableName specifies the table name for ProjectToken
+func (ProjectToken) TableName() string {
+	return "project_service_tokens"
+}
+
+// ProjectTokenUsage records one API request made with a project token
+type ProjectTokenUsage struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	TokenID   uint      `json:"token_id" gorm:"index"`
+	ProjectID uint      `json:"project_id" gorm:"not null;index"`
+	// Version is the token version used; a lower one than the token's
+	// means a consumer still runs with the value from before a rotation
+	Version   int    `json:"version"`
+	Method    string `json:"method" gorm:"size:10"`
+	Path      string `json:"path" gorm:"size:500"`
+	Status    int    `json:"status"`
+	IPAddress string `json:"ip_address,omitempty" gorm:"size:64"`
+}
+
+// TableName specifies the table name for ProjectTokenUsage
+func (ProjectTokenUsage) TableName() string {
+	return "project_service_token_usage"
+}
+
+// ProjectTokenObserver is told when a project's token rotates, so
+// long-running consumers such as hosted apps can pick up the new value.
+// It is called synchronously and must not block.
+type ProjectTokenObserver interface {
+	ProjectTokenRotated(projectID uint)
+}
+
+// ProjectTokens issues, rotates and authenticates project service tokens
+type ProjectTokens struct {
+	db       *gorm.DB
+	sm       *SecretsManager
+	apiURL   string
+	observer ProjectTokenObserver
+	now      func() time.Time
+}
+
+// NewProjectTokens creates a project token service. apiURL is the public
+// API origin given to consumers in APEX_API_URL; it may be empty.
+func NewProjectTokens(db *gorm.DB, sm *SecretsManager, apiURL string) *ProjectTokens {
+	return &ProjectTokens{
+		db:     db,
+		sm:     sm,
+		apiURL: strings.TrimRight(apiURL, "/"),
+		now:    time.Now,
+	}
+}
+
+// SetObserver reports rotations to observer
+func (p *ProjectTokens) SetObserver(observer ProjectTokenObserver) {
+	p.observer = observer
+}
+
+// AutoMigrate creates the project token tables
+func (p *ProjectTokens) AutoMigrate() error {
+	return p.db.AutoMigrate(&ProjectToken{}, &ProjectTokenUsage{})
+}
+
+func hashProjectToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newProjectTokenValue returns a fresh token for projectID. The project ID
+// is part of the token so a leaked one is easy to attribute.
+func newProjectTokenValue(projectID uint) (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d_%s", ProjectTokenPrefix, projectID, hex.EncodeToString(raw)), nil
+}
+
+// projectTokenPrefix is the displayable start of a token: the project ID
+// and the first six random characters
+func projectTokenPrefix(plaintext string) string {
+	return plaintext[:len(plaintext)-42]
+}
+
+// Enable gives a project a service token, or returns the existing one
+func (p *ProjectTokens) Enable(projectID, userID uint) (*ProjectToken, error) {
+	if token, err := p.Get(projectID); err == nil {
+		return token, nil
+	} else if !errors.Is(err, ErrProjectTokenNotFound) {
+		return nil, err
+	}
+
+	plaintext, err := newProjectTokenValue(projectID)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, salt, fingerprint, err := p.sm.Encrypt(userID, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	now := p.now()
+	token := &ProjectToken{
+		ProjectID:      projectID,
+		UserID:         userID,
+		Prefix:         projectTokenPrefix(plaintext),
+		TokenHash:      hashProjectToken(plaintext),
+		EncryptedValue: encrypted,
+		Salt:           salt,
+		KeyFingerprint: fingerprint,
+		Version:        1,
+		RotatedAt:      now,
+		NextRotationAt: now.Add(ProjectTokenRotationInterval),
+	}
+	if err := p.db.Create(token).Error; err != nil {
+		// Enabled concurrently
+		if existing, getErr := p.Get(projectID); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// Get returns a project's service token
+func (p *ProjectTokens) Get(projectID uint) (*ProjectToken, error) {
+	var token ProjectToken
+	if err := p.db.Where("project_id = ?", projectID).Take(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Disable revokes a project's service token immediately, including the
+// previous value. Usage records are kept.
+func (p *ProjectTokens) Disable(projectID uint) error {
+	result := p.db.Where("project_id = ?", projectID).Delete(&ProjectToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProjectTokenNotFound
+	}
+	return nil
+}
+
+// Rotate replaces a project's token. The old value keeps working for
+// ProjectTokenGracePeriod; the one before it stops at once.
+func (p *ProjectTokens) Rotate(projectID uint) (*ProjectToken, error) {
+	token, err := p.Get(projectID)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := newProjectTokenValue(projectID)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, salt, fingerprint, err := p.sm.Encrypt(token.UserID, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	now := p.now()
+	previousExpires := now.Add(ProjectTokenGracePeriod)
+	// The hash and owner checks leave a token rotated or reassigned meanwhile
+	// alone
+	result := p.db.Model(&ProjectToken{}).
+		Where("id = ? AND token_hash = ? AND user_id = ?", token.ID, token.TokenHash, token.UserID).
+		Updates(map[string]interface{}{
+			"prefix":              projectTokenPrefix(plaintext),
+			"token_hash":          hashProjectToken(plaintext),
+			"encrypted_value":     encrypted,
+			"salt":                salt,
+			"key_fingerprint":     fingerprint,
+			"previous_hash":       token.TokenHash,
+			"previous_expires_at": previousExpires,
+			"version":             token.Version + 1,
+			"rotated_at":          now,
+			"next_rotation_at":    now.Add(ProjectTokenRotationInterval),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return p.Get(projectID)
+	}
+	if p.observer != nil {
+		p.observer.ProjectTokenRotated(projectID)
+	}
+	return p.Get(projectID)
+}
+
+// RotateDue rotates every token past its rotation time and returns how many
+// were rotated
+func (p *ProjectTokens) RotateDue(ctx context.Context) (int, error) {
+	var due []ProjectToken
+	if err := p.db.WithContext(ctx).Where("next_rotation_at <= ?", p.now()).
+		Order("next_rotation_at").Limit(100).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+	rotated := 0
+	for _, token := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := p.Rotate(token.ProjectID); err != nil {
+			log.Printf("Project %d: failed to rotate service token: %v", token.ProjectID, err)
+			continue
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// Reassign re-encrypts a project's token for userID, the project's new
+// owner after a transfer. The value itself does not change, so running
+// consumers keep working. A project without a token is left alone.
+func (p *ProjectTokens) Reassign(projectID, userID uint) error {
+	token, err := p.Get(projectID)
+	if errors.Is(err, ErrProjectTokenNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if token.UserID == userID {
+		return nil
+	}
+	plaintext, err := p.sm.Decrypt(token.UserID, token.EncryptedValue, token.Salt)
+	if err != nil {
+		return fmt.Errorf("decrypt project token: %w", err)
+	}
+	encrypted, salt, fingerprint, err := p.sm.Encrypt(userID, plaintext)
+	if err != nil {
+		return err
+	}
+	// A token rotated meanwhile fails the hash check and is reassigned again
+	// from its new value
+	result := p.db.Model(&ProjectToken{}).
+		Where("id = ? AND token_hash = ?", token.ID, token.TokenHash).
+		Updates(map[string]interface{}{
+			"user_id":         userID,
+			"encrypted_value": encrypted,
+			"salt":            salt,
+			"key_fingerprint": fingerprint,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return p.Reassign(projectID, userID)
+	}
+	return nil
+}
+
+// Start rotates due tokens until ctx is cancelled
+func (p *ProjectTokens) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultProjectTokenCheckInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if rotated, err := p.RotateDue(ctx); err != nil {
+					log.Printf("Project token rotation failed: %v", err)
+				} else if rotated > 0 {
+					log.Printf("Rotated %d project service tokens", rotated)
+				}
+				if err := p.PruneUsage(ctx); err != nil {
+					log.Printf("Project token usage pruning failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Env returns the variables that let a project's jobs and apps call the
+// project API: the current token, the project ID and, when configured, the
+// API URL
+func (p *ProjectTokens) Env(projectID uint) (map[string]string, error) {
+	token, err := p.Get(projectID)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := p.sm.Decrypt(token.UserID, token.EncryptedValue, token.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt project token: %w", err)
+	}
+	env := map[string]string{
+		ProjectTokenEnvVar: plaintext,
+		ProjectIDEnvVar:    strconv.FormatUint(uint64(projectID), 10),
+	}
+	if p.apiURL != "" {
+		env[ProjectAPIURLEnvVar] = p.apiURL + "/api/v1/project-api"
+	}
+	return env, nil
+}
+
+// Authenticate resolves a plaintext token to its project's token. The
+// returned version is the one the caller presented.
+func (p *ProjectTokens) Authenticate(plaintext string) (*ProjectToken, int, error) {
+	if !strings.HasPrefix(plaintext, ProjectTokenPrefix) {
+		return nil, 0, ErrInvalidProjectToken
+	}
+	hash := hashProjectToken(plaintext)
+	var token ProjectToken
+	if err := p.db.Where("token_hash = ? OR previous_hash = ?", hash, hash).Take(&token).Error; err != nil {
+		return nil, 0, ErrInvalidProjectToken
+	}
+	now := p.now()
+	version := token.Version
+	if token.TokenHash != hash {
+		if token.PreviousExpiresAt == nil || now.After(*token.PreviousExpiresAt) {
+			return nil, 0, ErrInvalidProjectToken
+		}
+		version--
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > projectTokenTouchInterval {
+		p.db.Model(&ProjectToken{}).Where("id = ?", token.ID).Update("last_used_at", now)
+		token.LastUsedAt = &now
+	}
+	return &token, version, nil
+}
+
+// RecordUsage logs a request made with a token, keeping the most recent
+// ProjectTokenUsageLimit records of the token
+func (p *ProjectTokens) RecordUsage(usage *ProjectTokenUsage) {
+	if len(usage.Path) > 500 {
+		usage.Path = usage.Path[:500]
+	}
+	if err := p.db.Create(usage).Error; err != nil {
+		log.Printf("Project %d: failed to record service token usage: %v", usage.ProjectID, err)
+		return
+	}
+	// Once a token has a full history each write drops its oldest record,
+	// so the lookup stays bounded by the limit
+	var cutoff ProjectTokenUsage
+	if err := p.db.Select("id").Where("token_id = ?", usage.TokenID).Order("id DESC").
+		Offset(ProjectTokenUsageLimit).Take(&cutoff).Error; err == nil {
+		p.db.Where("token_id = ? AND id <= ?", usage.TokenID, cutoff.ID).Delete(&ProjectTokenUsage{})
+	}
+}
+
+// PruneUsage deletes usage records older than ProjectTokenUsageRetention
+func (p *ProjectTokens) PruneUsage(ctx context.Context) error {
+	return p.db.WithContext(ctx).
+		Where("created_at < ?", p.now().Add(-ProjectTokenUsageRetention)).
+		Delete(&ProjectTokenUsage{}).Error
+}
+
+// Usage returns a project's most recent token usage, newest first
+func (p *ProjectTokens) Usage(projectID uint, limit int) ([]ProjectTokenUsage, error) {
+	if limit <= 0 || limit > ProjectTokenUsageLimit {
+		limit = 100
+	}
+	var usage []ProjectTokenUsage
+	err := p.db.Where("project_id = ?", projectID).Order("id DESC").Limit(limit).Find(&usage).Error
+	return usage, err
+}
@@ -0,0 +1,201 @@
+package secrets
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type recordingObserver struct {
+	rotated []uint
+}
+
+func (o *recordingObserver) ProjectTokenRotated(projectID uint) {
+	o.rotated = append(o.rotated, projectID)
+}
+
+func setupProjectTokens(t *testing.T) (*ProjectTokens, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	key, _ := GenerateMasterKey()
+	sm, _ := NewSecretsManager(key)
+	sm.iterations = 1000
+	tokens := NewProjectTokens(db, sm, "https://api.example.com/")
+	if err := tokens.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return tokens, db
+}
+
+func TestProjectTokenEnvAuthenticatesForItsProject(t *testing.T) {
+	tokens, _ := setupProjectTokens(t)
+	if _, err := tokens.Env(4); err != ErrProjectTokenNotFound {
+		t.Fatalf("Env() before Enable = %v, want ErrProjectTokenNotFound", err)
+	}
+
+	token, err := tokens.Enable(4, 7)
+	if err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	again, err := tokens.Enable(4, 7)
+	if err != nil || again.ID != token.ID {
+		t.Fatalf("second Enable() = %+v, %v; want the existing token", again, err)
+	}
+	if !strings.HasPrefix(token.Prefix, ProjectTokenPrefix+"4_") {
+		t.Fatalf("Prefix = %q", token.Prefix)
+	}
+
+	env, err := tokens.Env(4)
+	if err != nil {
+		t.Fatalf("Env() error = %v", err)
+	}
+	if env[ProjectIDEnvVar] != "4" || env[ProjectAPIURLEnvVar] != "https://api.example.com/api/v1/project-api" {
+		t.Fatalf("Env() = %v", env)
+	}
+	plaintext := env[ProjectTokenEnvVar]
+	if !strings.HasPrefix(plaintext, token.Prefix) {
+		t.Fatalf("token %q does not start with prefix %q", plaintext, token.Prefix)
+	}
+
+	authed, version, err := tokens.Authenticate(plaintext)
+	if err != nil || authed.ProjectID != 4 || version != 1 {
+		t.Fatalf("Authenticate() = %+v, %d, %v", authed, version, err)
+	}
+	if authed.LastUsedAt == nil {
+		t.Fatal("Authenticate() did not record last use")
+	}
+	for _, bad := range []string{"", "apx_mgmt_abc", ProjectTokenPrefix + "4_deadbeef"} {
+		if _, _, err := tokens.Authenticate(bad); err != ErrInvalidProjectToken {
+			t.Fatalf("Authenticate(%q) = %v, want ErrInvalidProjectToken", bad, err)
+		}
+	}
+
+	if err := tokens.Disable(4); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	if _, _, err := tokens.Authenticate(plaintext); err != ErrInvalidProjectToken {
+		t.Fatalf("Authenticate() after Disable = %v", err)
+	}
+}
+
+func TestProjectTokenRotationKeepsPreviousValueForGracePeriod(t *testing.T) {
+	tokens, _ := setupProjectTokens(t)
+	observer := &recordingObserver{}
+	tokens.SetObserver(observer)
+	now := time.Now()
+	tokens.now = func() time.Time { return now }
+
+	if _, err := tokens.Enable(5, 7); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	env, _ := tokens.Env(5)
+	first := env[ProjectTokenEnvVar]
+
+	// Not due yet
+	if rotated, err := tokens.RotateDue(context.Background()); err != nil || rotated != 0 {
+		t.Fatalf("RotateDue() = %d, %v; want nothing due", rotated, err)
+	}
+	now = now.Add(ProjectTokenRotationInterval + time.Minute)
+	if rotated, err := tokens.RotateDue(context.Background()); err != nil || rotated != 1 {
+		t.Fatalf("RotateDue() = %d, %v; want 1", rotated, err)
+	}
+	if len(observer.rotated) != 1 || observer.rotated[0] != 5 {
+		t.Fatalf("observer saw %v", observer.rotated)
+	}
+
+	env, _ = tokens.Env(5)
+	second := env[ProjectTokenEnvVar]
+	if second == first {
+		t.Fatal("rotation kept the same value")
+	}
+	if _, version, err := tokens.Authenticate(second); err != nil || version != 2 {
+		t.Fatalf("Authenticate(new) = %d, %v", version, err)
+	}
+	if _, version, err := tokens.Authenticate(first); err != nil || version != 1 {
+		t.Fatalf("Authenticate(previous) within grace = %d, %v", version, err)
+	}
+
+	now = now.Add(ProjectTokenGracePeriod + time.Minute)
+	if _, _, err := tokens.Authenticate(first); err != ErrInvalidProjectToken {
+		t.Fatalf("Authenticate(previous) after grace = %v", err)
+	}
+
+	// A second rotation drops the first value at once
+	if _, err := tokens.Rotate(5); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if _, _, err := tokens.Authenticate(second); err != nil {
+		t.Fatalf("Authenticate(previous) after manual rotation = %v", err)
+	}
+	if _, _, err := tokens.Authenticate(first); err != ErrInvalidProjectToken {
+		t.Fatalf("Authenticate(two rotations ago) = %v", err)
+	}
+}
+
+func TestProjectTokenUsageIsRecordedAndPruned(t *testing.T) {
+	tokens, db := setupProjectTokens(t)
+	for i := 0; i < ProjectTokenUsageLimit+53; i++ {
+		tokens.RecordUsage(&ProjectTokenUsage{TokenID: 1, ProjectID: 6, Version: 1, Method: "GET", Path: "/api/v1/project-api/files", Status: 200})
+		// A quieter token writing in between must neither stop the busy
+		// one from being pruned nor lose its own records
+		if i%100 == 0 {
+			tokens.RecordUsage(&ProjectTokenUsage{TokenID: 2, ProjectID: 8, Method: "PUT", Path: "/api/v1/project-api/outputs/a.json", Status: 200})
+		}
+	}
+
+	var kept int64
+	db.Model(&ProjectTokenUsage{}).Where("token_id = ?", 1).Count(&kept)
+	if kept != ProjectTokenUsageLimit {
+		t.Fatalf("kept %d usage records, want %d", kept, ProjectTokenUsageLimit)
+	}
+	usage, err := tokens.Usage(8, 0)
+	if err != nil || len(usage) != 6 || usage[0].Method != "PUT" {
+		t.Fatalf("Usage() = %+v, %v", usage, err)
+	}
+
+	// Records past the retention window go, whatever the token
+	now := time.Now()
+	db.Model(&ProjectTokenUsage{}).Where("token_id = ?", 2).Update("created_at", now.Add(-ProjectTokenUsageRetention-time.Hour))
+	if err := tokens.PruneUsage(context.Background()); err != nil {
+		t.Fatalf("PruneUsage() error = %v", err)
+	}
+	if usage, _ := tokens.Usage(8, 0); len(usage) != 0 {
+		t.Fatalf("Usage() after PruneUsage = %+v", usage)
+	}
+	db.Model(&ProjectTokenUsage{}).Where("token_id = ?", 1).Count(&kept)
+	if kept != ProjectTokenUsageLimit {
+		t.Fatalf("PruneUsage() removed recent records, %d left", kept)
+	}
+}
+
+func TestProjectTokenReassignReencryptsForNewOwner(t *testing.T) {
+	tokens, _ := setupProjectTokens(t)
+	if err := tokens.Reassign(4, 9); err != nil {
+		t.Fatalf("Reassign() without a token = %v", err)
+	}
+	if _, err := tokens.Enable(4, 7); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	before, _ := tokens.Env(4)
+
+	if err := tokens.Reassign(4, 9); err != nil {
+		t.Fatalf("Reassign() error = %v", err)
+	}
+	token, err := tokens.Get(4)
+	if err != nil || token.UserID != 9 {
+		t.Fatalf("Get() = %+v, %v; want the token to belong to user 9", token, err)
+	}
+	if plaintext, err := tokens.sm.Decrypt(9, token.EncryptedValue, token.Salt); err != nil || plaintext != before[ProjectTokenEnvVar] {
+		t.Fatalf("value for the new owner = %q, %v; want the unchanged token", plaintext, err)
+	}
+	if after, err := tokens.Env(4); err != nil || after[ProjectTokenEnvVar] != before[ProjectTokenEnvVar] {
+		t.Fatalf("Env() after Reassign = %v, %v", after, err)
+	}
+}
@@ -0,0 +1,393 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rotation job states
+const (
+	RotationRunning   = "running"
+	RotationVerifying = "verifying"
+	RotationCompleted = "completed"
+	RotationFailed    = "failed"
+	RotationCancelled = "cancelled"
+)
+
+const (
+	// RotationBatchSize is how many rows a rotation job re-encrypts at once
+	RotationBatchSize = 100
+	// RotationVerifySample is how many values per table are decrypted with
+	// only the new key once a job finishes
+	RotationVerifySample = 50
+
+	maxRotationErrors = 50
+)
+
+var (
+	// ErrNoPreviousKey is returned when a rotation starts without
+	// SECRETS_MASTER_KEY_OLD
+	ErrNoPreviousKey = errors.New("no previous master key configured")
+	// ErrRotationInProgress is returned when a rotation job is already running
+	ErrRotationInProgress = errors.New("a rotation job is already running")
+	// ErrRotationJobNotFound is returned for unknown rotation jobs
+	ErrRotationJobNotFound = errors.New("rotation job not found")
+)
+
+// RotationJob tracks an online master key rotation. Values are moved from
+// the previous master key to the current one in batches while both keys
+// decrypt, so the platform keeps serving secrets throughout.
+type RotationJob struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Status    string `json:"status" gorm:"not null;size:20;index"`
+	StartedBy uint   `json:"started_by"`
+
+	Total       int `json:"total"`
+	Processed   int `json:"processed"`
+	Reencrypted int `json:"reencrypted"`
+	Skipped     int `json:"skipped"` // Already under the current key
+	Failed      int `json:"failed"`
+
+	// Resume position: the table being processed and the last row ID done
+	CurrentTable string `json:"current_table"`
+	Cursor       uint   `json:"cursor"`
+
+	RewrappedOrgKeys int `json:"rewrapped_org_keys"`
+	VerifiedSample   int `json:"verified_sample"`
+	VerifiedOK       int `json:"verified_ok"`
+
+	Errors      []string   `json:"errors,omitempty" gorm:"serializer:json;type:text"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName specifies the table name for RotationJob
+func (RotationJob) TableName() string {
+	return "secret_rotation_jobs"
+}
+
+// Progress returns the share of rows processed, from 0 to 1
+func (j *RotationJob) Progress() float64 {
+	if j.Total == 0 {
+		return 1
+	}
+	return float64(j.Processed) / float64(j.Total)
+}
+
+func (j *RotationJob) addError(message string) {
+	j.Failed++
+	if len(j.Errors) < maxRotationErrors {
+		j.Errors = append(j.Errors, message)
+	}
+}
+
+// RotationRunner runs rotation jobs in the background
+type RotationRunner struct {
+	db      *gorm.DB
+	sm      *SecretsManager
+	columns []EncryptedColumn
+	pause   time.Duration // Between batches, to limit database load
+
+	mu      sync.Mutex
+	running map[uint]context.CancelFunc
+}
+
+// NewRotationRunner creates a rotation job runner
+func NewRotationRunner(db *gorm.DB, sm *SecretsManager) *RotationRunner {
+	return &RotationRunner{
+		db:      db,
+		sm:      sm,
+		columns: DefaultEncryptedColumns,
+		pause:   200 * time.Millisecond,
+		running: make(map[uint]context.CancelFunc),
+	}
+}
+
+// Start begins a rotation job from the previous master key to the current one
+func (r *RotationRunner) Start(startedBy uint) (*RotationJob, error) {
+	if !r.sm.HasPreviousMasterKey() {
+		return nil, ErrNoPreviousKey
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var running int64
+	if err := r.db.Model(&RotationJob{}).Where("status IN ?", []string{RotationRunning, RotationVerifying}).Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrRotationInProgress
+	}
+
+	job := &RotationJob{Status: RotationRunning, StartedBy: startedBy}
+	for _, column := range r.columns {
+		if !r.db.Migrator().HasTable(column.Table) {
+			continue
+		}
+		var count int64
+		if err := r.db.Table(column.Table).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		job.Total += int(count)
+	}
+	if err := r.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	r.launch(job)
+	return job, nil
+}
+
+// ResumeInterrupted restarts jobs left running by a previous process
+func (r *RotationRunner) ResumeInterrupted() {
+	var jobs []RotationJob
+	if err := r.db.Where("status IN ?", []string{RotationRunning, RotationVerifying}).Find(&jobs).Error; err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range jobs {
+		if !r.sm.HasPreviousMasterKey() && jobs[i].Status == RotationRunning {
+			r.finish(&jobs[i], RotationFailed, "SECRETS_MASTER_KEY_OLD was removed before the job finished")
+			continue
+		}
+		log.Printf("Resuming secret rotation job %d at %s/%d", jobs[i].ID, jobs[i].CurrentTable, jobs[i].Cursor)
+		r.launch(&jobs[i])
+	}
+}
+
+// launch runs a job in the background. r.mu must be held.
+func (r *RotationRunner) launch(job *RotationJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.running[job.ID] = cancel
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, job.ID)
+			r.mu.Unlock()
+			cancel()
+		}()
+		r.run(ctx, job)
+	}()
+}
+
+// Cancel stops a running job. Rows already moved stay under the new key.
+func (r *RotationRunner) Cancel(jobID uint) (*RotationJob, error) {
+	r.mu.Lock()
+	cancel, ok := r.running[jobID]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+		return r.Get(jobID)
+	}
+	job, err := r.Get(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == RotationRunning || job.Status == RotationVerifying {
+		// Running in another process or orphaned
+		r.finish(job, RotationCancelled, "")
+	}
+	return job, nil
+}
+
+// Get returns a rotation job
+func (r *RotationRunner) Get(jobID uint) (*RotationJob, error) {
+	var job RotationJob
+	if err := r.db.First(&job, jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRotationJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List returns recent rotation jobs, newest first
+func (r *RotationRunner) List(limit int) ([]RotationJob, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var jobs []RotationJob
+	err := r.db.Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *RotationRunner) run(ctx context.Context, job *RotationJob) {
+	if job.Status == RotationRunning {
+		if job.CurrentTable == "" && job.Cursor == 0 {
+			// Local organization keys are wrapped by the master key too
+			from, _ := r.sm.KeyWrapper(ctx, KMSProviderLocal, "")
+			to := localWrapper{key: localKEK(r.sm.masterKey)}
+			rewrapped, err := rewrapLocalOrgKeys(ctx, r.db, from, to)
+			job.RewrappedOrgKeys = rewrapped
+			if err != nil {
+				r.finish(job, RotationFailed, fmt.Sprintf("re-wrapping organization keys: %v", err))
+				return
+			}
+		}
+
+		started := job.CurrentTable == ""
+		for _, column := range r.columns {
+			if !started {
+				if column.Table != job.CurrentTable {
+					continue
+				}
+				started = true
+			} else if job.CurrentTable != column.Table {
+				job.CurrentTable, job.Cursor = column.Table, 0
+			}
+			if !r.db.Migrator().HasTable(column.Table) {
+				continue
+			}
+			if err := r.rotateColumn(ctx, job, column); err != nil {
+				if ctx.Err() != nil {
+					r.finish(job, RotationCancelled, "")
+				} else {
+					r.finish(job, RotationFailed, err.Error())
+				}
+				return
+			}
+		}
+		job.Status = RotationVerifying
+		r.save(job)
+	}
+
+	r.verify(job)
+	if job.VerifiedOK < job.VerifiedSample {
+		r.finish(job, RotationFailed, fmt.Sprintf("%d of %d sampled values did not decrypt with the current key",
+			job.VerifiedSample-job.VerifiedOK, job.VerifiedSample))
+		return
+	}
+	r.finish(job, RotationCompleted, "")
+	log.Printf("Secret rotation job %d complete: %d re-encrypted, %d already current, %d failed",
+		job.ID, job.Reencrypted, job.Skipped, job.Failed)
+}
+
+// rotateColumn moves one table's rows to the current key, a batch at a time
+func (r *RotationRunner) rotateColumn(ctx context.Context, job *RotationJob, column EncryptedColumn) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var rows []rotationRow
+		err := r.db.Table(column.Table).
+			Select(fmt.Sprintf("id, %s AS user_id, %s AS value, %s AS salt", column.UserID, column.Value, column.Salt)).
+			Where("id > ?", job.Cursor).Order("id").Limit(RotationBatchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			r.rotateRow(job, column, row)
+			job.Cursor = row.ID
+			job.Processed++
+		}
+		r.save(job)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pause):
+		}
+	}
+}
+
+type rotationRow struct {
+	ID     uint
+	UserID uint
+	Value  string
+	Salt   string
+}
+
+func (r *RotationRunner) rotateRow(job *RotationJob, column EncryptedColumn, row rotationRow) {
+	if IsOrgCiphertext(row.Value) {
+		// Under an organization data key, which was re-wrapped above
+		job.Skipped++
+		return
+	}
+	if _, err := r.sm.decryptWith(r.sm.masterKey, row.UserID, row.Value, row.Salt); err == nil {
+		job.Skipped++
+		return
+	}
+	plaintext, err := r.sm.decryptWith(r.sm.previousKey, row.UserID, row.Value, row.Salt)
+	if err != nil {
+		job.addError(fmt.Sprintf("%s %d: decrypt failed with both keys", column.Table, row.ID))
+		return
+	}
+	encrypted, salt, fingerprint, err := r.sm.Encrypt(row.UserID, plaintext)
+	if err != nil {
+		job.addError(fmt.Sprintf("%s %d: encrypt failed: %v", column.Table, row.ID, err))
+		return
+	}
+	// The value check leaves rows rewritten meanwhile alone; they already
+	// use the current key
+	result := r.db.Table(column.Table).
+		Where(fmt.Sprintf("id = ? AND %s = ?", column.Value), row.ID, row.Value).
+		Updates(column.updates(encrypted, salt, fingerprint))
+	if result.Error != nil {
+		job.addError(fmt.Sprintf("%s %d: update failed: %v", column.Table, row.ID, result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		job.Skipped++
+		return
+	}
+	job.Reencrypted++
+}
+
+// verify decrypts a random sample of each table with only the current key
+func (r *RotationRunner) verify(job *RotationJob) {
+	job.VerifiedSample, job.VerifiedOK = 0, 0
+	for _, column := range r.columns {
+		if !r.db.Migrator().HasTable(column.Table) {
+			continue
+		}
+		var rows []rotationRow
+		err := r.db.Table(column.Table).
+			Select(fmt.Sprintf("id, %s AS user_id, %s AS value, %s AS salt", column.UserID, column.Value, column.Salt)).
+			Order("RANDOM()").Limit(RotationVerifySample).
+			Scan(&rows).Error
+		if err != nil {
+			job.addError(fmt.Sprintf("%s: sampling failed: %v", column.Table, err))
+			continue
+		}
+		for _, row := range rows {
+			job.VerifiedSample++
+			var err error
+			if IsOrgCiphertext(row.Value) {
+				_, err = r.sm.Decrypt(row.UserID, row.Value, row.Salt)
+			} else {
+				_, err = r.sm.decryptWith(r.sm.masterKey, row.UserID, row.Value, row.Salt)
+			}
+			if err == nil {
+				job.VerifiedOK++
+			}
+		}
+	}
+}
+
+func (r *RotationRunner) save(job *RotationJob) {
+	if err := r.db.Save(job).Error; err != nil {
+		log.Printf("Secret rotation job %d: failed to save progress: %v", job.ID, err)
+	}
+}
+
+func (r *RotationRunner) finish(job *RotationJob, status, message string) {
+	now := time.Now()
+	job.Status = status
+	job.Error = message
+	job.CompletedAt = &now
+	r.save(job)
+}
@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRotationRunnerMovesValuesToCurrentKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&Secret{}, &OrgDataKey{}, &RotationJob{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	oldKey, _ := GenerateMasterKey()
+	newKey, _ := GenerateMasterKey()
+	old, _ := NewSecretsManager(oldKey)
+	old.iterations = 1000
+	for _, value := range []string{"alpha", "beta", "gamma"} {
+		storeTestSecret(t, db, old, 1, value)
+	}
+
+	sm, _ := NewSecretsManager(newKey)
+	sm.iterations = 1000
+	runner := NewRotationRunner(db, sm)
+	runner.pause = 0
+	if _, err := runner.Start(1); err != ErrNoPreviousKey {
+		t.Fatalf("Start() without a previous key = %v, want ErrNoPreviousKey", err)
+	}
+	if err := sm.SetPreviousMasterKey(oldKey); err != nil {
+		t.Fatalf("SetPreviousMasterKey() error = %v", err)
+	}
+	current := storeTestSecret(t, db, sm, 1, "delta")
+
+	// Both keys read during the transition
+	var secrets []Secret
+	db.Order("id").Find(&secrets)
+	for _, s := range secrets {
+		if value, err := sm.Decrypt(1, s.EncryptedValue, s.Salt); err != nil || value != s.Name {
+			t.Fatalf("Decrypt(%s) = %q, %v", s.Name, value, err)
+		}
+	}
+
+	job, err := runner.Start(1)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if job.Total != 4 {
+		t.Fatalf("Total = %d, want 4", job.Total)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, _ = runner.Get(job.ID)
+		if job.Status != RotationRunning && job.Status != RotationVerifying {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not finish: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != RotationCompleted || job.Reencrypted != 3 || job.Skipped != 1 || job.Processed != 4 {
+		t.Fatalf("job = %+v", job)
+	}
+	if job.VerifiedSample != 4 || job.VerifiedOK != 4 {
+		t.Fatalf("verification = %d/%d", job.VerifiedOK, job.VerifiedSample)
+	}
+
+	fresh, _ := NewSecretsManager(newKey)
+	fresh.iterations = 1000
+	db.Order("id").Find(&secrets)
+	for _, s := range secrets {
+		if value, err := fresh.Decrypt(1, s.EncryptedValue, s.Salt); err != nil || value != s.Name {
+			t.Fatalf("new key alone cannot decrypt %s: %q, %v", s.Name, value, err)
+		}
+		if s.ID == current.ID && s.EncryptedValue != current.EncryptedValue {
+			t.Fatal("values already under the current key must not be rewritten")
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,10 +19,10 @@ import (
 )
 
 var (
-	ErrInvalidKey      = errors.New("invalid encryption key")
+	ErrInvalidKey       = errors.New("invalid encryption key")
 	ErrDecryptionFailed = errors.New("decryption failed - data may be corrupted or key is wrong")
-	ErrSecretNotFound  = errors.New("secret not found")
-	ErrInvalidSecret   = errors.New("invalid secret format")
+	ErrSecretNotFound   = errors.New("secret not found")
+	ErrInvalidSecret    = errors.New("invalid secret format")
 )
 
 // SecretType categorizes secrets for better organization
@@ -36,51 +37,66 @@ const (
 	SecretTypeGeneric     SecretType = "generic"
 )
 
+// PlaceholderNote ends the description of environment secrets created
+// without a value, so the owner can see which ones to fill in. IsPlaceholder
+// is what callers check; the note is only for display.
+const PlaceholderNote = "(placeholder: set a value before running)"
+
 // Secret represents an encrypted secret
 type Secret struct {
-	ID           uint       `json:"id" gorm:"primaryKey"`
-	UserID       uint       `json:"user_id" gorm:"index;not null"`
-	ProjectID    *uint      `json:"project_id,omitempty" gorm:"index"`
-	Name         string     `json:"name" gorm:"not null"`
-	Description  string     `json:"description,omitempty"`
-	Type         SecretType `json:"type" gorm:"default:'generic'"`
-	EncryptedValue string   `json:"-" gorm:"not null"` // Never expose in JSON
-	KeyFingerprint string   `json:"-" gorm:"not null"` // For key rotation
-	Salt           string   `json:"-" gorm:"not null"` // Unique salt per secret
-	LastAccessed *time.Time `json:"last_accessed,omitempty"`
-	RotationDue  *time.Time `json:"rotation_due,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	UserID         uint       `json:"user_id" gorm:"index;not null"`
+	ProjectID      *uint      `json:"project_id,omitempty" gorm:"index"`
+	Name           string     `json:"name" gorm:"not null"`
+	Description    string     `json:"description,omitempty"`
+	Type           SecretType `json:"type" gorm:"default:'generic'"`
+	EncryptedValue string     `json:"-" gorm:"not null"`                   // Never expose in JSON
+	KeyFingerprint string     `json:"-" gorm:"not null"`                   // For key rotation
+	Salt           string     `json:"-" gorm:"not null"`                   // Unique salt per secret
+	IsPlaceholder  bool       `json:"is_placeholder" gorm:"default:false"` // Created without a value
+	LastAccessed   *time.Time `json:"last_accessed,omitempty"`
+	RotationDue    *time.Time `json:"rotation_due,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 // SecretMetadata is the safe-to-expose secret info (no values)
 type SecretMetadata struct {
-	ID          uint       `json:"id"`
-	Name        string     `json:"name"`
-	Description string     `json:"description,omitempty"`
-	Type        SecretType `json:"type"`
-	ProjectID   *uint      `json:"project_id,omitempty"`
-	LastAccessed *time.Time `json:"last_accessed,omitempty"`
-	RotationDue *time.Time `json:"rotation_due,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID            uint       `json:"id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description,omitempty"`
+	Type          SecretType `json:"type"`
+	ProjectID     *uint      `json:"project_id,omitempty"`
+	IsPlaceholder bool       `json:"is_placeholder,omitempty"`
+	LastAccessed  *time.Time `json:"last_accessed,omitempty"`
+	RotationDue   *time.Time `json:"rotation_due,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 // ToMetadata converts a Secret to safe metadata
 func (s *Secret) ToMetadata() SecretMetadata {
 	return SecretMetadata{
-		ID:           s.ID,
-		Name:         s.Name,
-		Description:  s.Description,
-		Type:         s.Type,
-		ProjectID:    s.ProjectID,
-		LastAccessed: s.LastAccessed,
-		RotationDue:  s.RotationDue,
-		CreatedAt:    s.CreatedAt,
-		UpdatedAt:    s.UpdatedAt,
+		ID:            s.ID,
+		Name:          s.Name,
+		Description:   s.Description,
+		Type:          s.Type,
+		ProjectID:     s.ProjectID,
+		IsPlaceholder: s.IsPlaceholder,
+		LastAccessed:  s.LastAccessed,
+		RotationDue:   s.RotationDue,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
 	}
 }
 
+// ClearPlaceholder marks the secret as holding a real value and drops the
+// placeholder note from its description
+func (s *Secret) ClearPlaceholder() {
+	s.IsPlaceholder = false
+	s.Description = strings.TrimSpace(strings.TrimSuffix(s.Description, PlaceholderNote))
+}
+
 // EncryptionKey represents a derived key for encryption/decryption
 type EncryptionKey struct {
 	key         []byte
@@ -89,10 +105,12 @@ type EncryptionKey struct {
 
 // SecretsManager handles secure secret storage and retrieval
 type SecretsManager struct {
-	masterKey     []byte
-	keyCache      map[uint]*EncryptionKey // UserID -> derived key
-	mu            sync.RWMutex
-	iterations    int // PBKDF2 iterations
+	masterKey   []byte
+	previousKey []byte                  // Old master key, still decrypting during a rotation
+	keyCache    map[uint]*EncryptionKey // UserID -> derived key
+	mu          sync.RWMutex
+	iterations  int         // PBKDF2 iterations
+	orgKeys     *OrgKeyring // Optional per-organization data keys
 }
 
 // normalizeMasterKeyBytes accepts either a base64-encoded 32-byte key or a
@@ -143,11 +161,31 @@ func GenerateMasterKey() (string, error) {
 	return base64.StdEncoding.EncodeToString(key), nil
 }
 
+// SetPreviousMasterKey keeps an old master key for decryption while a
+// rotation job moves values to the current one
+func (sm *SecretsManager) SetPreviousMasterKey(masterKey string) error {
+	previous, err := normalizeMasterKeyBytes(masterKey)
+	if err != nil {
+		return err
+	}
+	sm.previousKey = previous
+	return nil
+}
+
+// HasPreviousMasterKey reports whether an old master key is configured
+func (sm *SecretsManager) HasPreviousMasterKey() bool {
+	return len(sm.previousKey) > 0
+}
+
 // deriveUserKey creates a unique encryption key for each user
 func (sm *SecretsManager) deriveUserKey(userID uint, salt []byte) *EncryptionKey {
+	return sm.deriveUserKeyFrom(sm.masterKey, userID, salt)
+}
+
+func (sm *SecretsManager) deriveUserKeyFrom(masterKey []byte, userID uint, salt []byte) *EncryptionKey {
 	// Combine master key with user-specific data
 	userBytes := []byte(fmt.Sprintf("user:%d", userID))
-	combined := append(sm.masterKey, userBytes...)
+	combined := append(append([]byte{}, masterKey...), userBytes...)
 
 	// Derive key using PBKDF2
 	key := pbkdf2.Key(combined, salt, sm.iterations, 32, sha256.New)
@@ -172,6 +210,14 @@ func generateSalt() ([]byte, error) {
 
 // Encrypt encrypts a secret value for storage
 func (sm *SecretsManager) Encrypt(userID uint, value string) (encryptedValue, saltBase64, keyFingerprint string, err error) {
+	if sm.orgKeys != nil {
+		// Members of an organization with its own key use that key
+		encryptedValue, saltBase64, keyFingerprint, ok, err := sm.orgKeys.encrypt(userID, value)
+		if err != nil || ok {
+			return encryptedValue, saltBase64, keyFingerprint, err
+		}
+	}
+
 	salt, err := generateSalt()
 	if err != nil {
 		return "", "", "", err
@@ -208,6 +254,23 @@ func (sm *SecretsManager) Encrypt(userID uint, value string) (encryptedValue, sa
 
 // Decrypt decrypts a secret value
 func (sm *SecretsManager) Decrypt(userID uint, encryptedValue, saltBase64 string) (string, error) {
+	if IsOrgCiphertext(encryptedValue) {
+		if sm.orgKeys == nil {
+			return "", ErrOrgKeyNotFound
+		}
+		return sm.orgKeys.decrypt(userID, encryptedValue, saltBase64)
+	}
+
+	plaintext, err := sm.decryptWith(sm.masterKey, userID, encryptedValue, saltBase64)
+	if errors.Is(err, ErrDecryptionFailed) && len(sm.previousKey) > 0 {
+		// Not yet re-encrypted by the rotation job
+		return sm.decryptWith(sm.previousKey, userID, encryptedValue, saltBase64)
+	}
+	return plaintext, err
+}
+
+// decryptWith decrypts a master-key value with the given master key
+func (sm *SecretsManager) decryptWith(masterKey []byte, userID uint, encryptedValue, saltBase64 string) (string, error) {
 	salt, err := base64.StdEncoding.DecodeString(saltBase64)
 	if err != nil {
 		return "", fmt.Errorf("invalid salt: %w", err)
@@ -218,7 +281,7 @@ func (sm *SecretsManager) Decrypt(userID uint, encryptedValue, saltBase64 string
 		return "", fmt.Errorf("invalid ciphertext: %w", err)
 	}
 
-	encKey := sm.deriveUserKey(userID, salt)
+	encKey := sm.deriveUserKeyFrom(masterKey, userID, salt)
 
 	// Create AES cipher
 	block, err := aes.NewCipher(encKey.key)
@@ -266,6 +329,21 @@ func (sm *SecretsManager) DecryptJSON(userID uint, encryptedValue, saltBase64 st
 
 // ValidateKeyFingerprint checks if a secret was encrypted with the current key
 func (sm *SecretsManager) ValidateKeyFingerprint(userID uint, saltBase64, storedFingerprint string) (bool, error) {
+	if strings.HasPrefix(storedFingerprint, "org:") {
+		if sm.orgKeys == nil {
+			return false, nil
+		}
+		orgID, err := sm.orgKeys.organizationFor(userID)
+		if err != nil || orgID == 0 {
+			return false, err
+		}
+		key, err := sm.orgKeys.ActiveKey(orgID)
+		if err != nil {
+			return false, err
+		}
+		return key.Fingerprint == storedFingerprint, nil
+	}
+
 	salt, err := base64.StdEncoding.DecodeString(saltBase64)
 	if err != nil {
 		return false, fmt.Errorf("invalid salt: %w", err)
@@ -292,10 +370,10 @@ func (sm *SecretsManager) DecryptEnvVars(userID uint, encryptedValue, saltBase64
 
 // OAuthCredentials represents OAuth client credentials
 type OAuthCredentials struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-	RefreshToken string `json:"refresh_token,omitempty"`
-	AccessToken  string `json:"access_token,omitempty"`
+	ClientID     string     `json:"client_id"`
+	ClientSecret string     `json:"client_secret"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	AccessToken  string     `json:"access_token,omitempty"`
 	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 }
 
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrBlobTooLarge is returned by PutBlob when the content exceeds maxSize.
+var ErrBlobTooLarge = errors.New("blob exceeds maximum size")
+
+// BlobKey returns the content-addressed object key for a SHA-256 hex digest.
+// Identical content shares one object, so blobs are never deleted when a
+// single file referencing them goes away.
+func BlobKey(hash string) string {
+	return "blobs/sha256/" + hash[:2] + "/" + hash
+}
+
+// ValidBlobHash reports whether hash is a lowercase SHA-256 hex digest.
+func ValidBlobHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, r := range hash {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// PutBlob streams r into the store under the SHA-256 of its content and
+// returns the digest and size. The content is spooled to a temp file first
+// since the key isn't known until it has been read; content already in the
+// store is not uploaded again.
+func PutBlob(ctx context.Context, p Provider, r io.Reader, maxSize int64, contentType string) (string, int64, error) {
+	spool, err := os.CreateTemp("", "apex-blob-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob spool: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hasher := sha256.New()
+	limited := io.LimitReader(r, maxSize+1)
+	size, err := io.Copy(io.MultiWriter(spool, hasher), limited)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read blob: %w", err)
+	}
+	if size > maxSize {
+		return "", 0, ErrBlobTooLarge
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	key := BlobKey(hash)
+
+	if exists, err := p.Exists(ctx, key); err == nil && exists {
+		return hash, size, nil
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", 0, fmt.Errorf("failed to rewind blob spool: %w", err)
+	}
+	if err := p.Put(ctx, key, spool, size, contentType); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+	return hash, size, nil
+}
+
+// ReadBlob reads a whole blob into memory, for callers such as previews that
+// need the bytes rather than a stream.
+func ReadBlob(ctx context.Context, p Provider, hash string) ([]byte, error) {
+	if !ValidBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash %q", hash)
+	}
+	reader, _, err := p.Get(ctx, BlobKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// blobReadSeeker adapts an object to io.ReadSeeker by reopening it with a
+// ranged read after each seek, so http.ServeContent can answer Range
+// requests without downloading the whole object.
+type blobReadSeeker struct {
+	ctx    context.Context
+	p      Provider
+	key    string
+	size   int64
+	pos    int64
+	reader io.ReadCloser
+}
+
+// NewReadSeeker returns a lazily-opened io.ReadSeekCloser over the object at
+// key, whose total size must be known. Caller must close it.
+func NewReadSeeker(ctx context.Context, p Provider, key string, size int64) io.ReadSeekCloser {
+	return &blobReadSeeker{ctx: ctx, p: p, key: key, size: size}
+}
+
+func (b *blobReadSeeker) Read(buf []byte) (int, error) {
+	if b.pos >= b.size {
+		return 0, io.EOF
+	}
+	if b.reader == nil {
+		reader, err := GetRange(b.ctx, b.p, b.key, b.pos, b.size-b.pos)
+		if err != nil {
+			return 0, err
+		}
+		b.reader = reader
+	}
+	n, err := b.reader.Read(buf)
+	b.pos += int64(n)
+	return n, err
+}
+
+func (b *blobReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = b.pos + offset
+	case io.SeekEnd:
+		pos = b.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("negative position %d", pos)
+	}
+	if pos != b.pos && b.reader != nil {
+		b.reader.Close()
+		b.reader = nil
+	}
+	b.pos = pos
+	return pos, nil
+}
+
+func (b *blobReadSeeker) Close() error {
+	if b.reader == nil {
+		return nil
+	}
+	err := b.reader.Close()
+	b.reader = nil
+	return err
+}
@@ -112,4 +112,21 @@ func (l *LocalProvider) Exists(ctx context.Context, key string) (bool, error) {
 	}
 
 	return true, nil
-}
\ No newline at end of file
+}
+// GetRange reads length bytes starting at offset from local disk
+func (l *LocalProvider) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	filePath := filepath.Join(l.baseDir, key)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", key)
+		}
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
@@ -95,6 +95,25 @@ func (r *R2Provider) Put(ctx context.Context, key string, reader io.Reader, size
 	return nil
 }
 
+// PutCold uploads content to R2's Infrequent Access storage class.
+func (r *R2Provider) PutCold(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(r.bucket),
+		Key:           aws.String(key),
+		Body:          reader,
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+		StorageClass:  types.StorageClassStandardIa,
+	}
+
+	_, err := r.uploader.Upload(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to upload to R2 cold tier: %w", err)
+	}
+
+	return nil
+}
+
 // Get downloads content from R2
 func (r *R2Provider) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
 	input := &s3.GetObjectInput{
@@ -115,6 +134,22 @@ func (r *R2Provider) Get(ctx context.Context, key string) (io.ReadCloser, int64,
 	return result.Body, size, nil
 }
 
+// GetRange downloads length bytes starting at offset from R2
+func (r *R2Provider) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	}
+
+	result, err := r.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get range from R2: %w", err)
+	}
+
+	return result.Body, nil
+}
+
 // Delete removes an object from R2
 func (r *R2Provider) Delete(ctx context.Context, key string) error {
 	input := &s3.DeleteObjectInput{
@@ -18,4 +18,46 @@ type Provider interface {
 	URL(ctx context.Context, key string, ttl time.Duration) (string, error)
 	// Exists returns true if the object exists.
 	Exists(ctx context.Context, key string) (bool, error)
-}
\ No newline at end of file
+}
+
+// ColdProvider is implemented by backends that offer an infrequent-access
+// tier for data that is rarely read back (e.g. archived projects).
+type ColdProvider interface {
+	PutCold(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+}
+
+// PutCold stores content in the provider's cold tier when it has one and
+// falls back to a regular Put otherwise.
+func PutCold(ctx context.Context, p Provider, key string, r io.Reader, size int64, contentType string) error {
+	if cold, ok := p.(ColdProvider); ok {
+		return cold.PutCold(ctx, key, r, size, contentType)
+	}
+	return p.Put(ctx, key, r, size, contentType)
+}
+
+// RangeProvider is implemented by backends that can read part of an object
+// without fetching the whole thing.
+type RangeProvider interface {
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// GetRange returns a reader over length bytes of an object starting at
+// offset. Backends without native range reads fall back to skipping ahead in
+// a full read.
+func GetRange(ctx context.Context, p Provider, key string, offset, length int64) (io.ReadCloser, error) {
+	if ranged, ok := p.(RangeProvider); ok {
+		return ranged.GetRange(ctx, key, offset, length)
+	}
+	reader, _, err := p.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, nil
+}
@@ -0,0 +1,136 @@
+package supportbundles
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"apex-build/internal/deploy"
+	"apex-build/internal/hosting"
+
+	"gorm.io/gorm"
+)
+
+// deploymentLogRow reads deployment_logs, which provider deployments
+// (phase) and APEX hosting (source) share.
+type deploymentLogRow struct {
+	Timestamp time.Time
+	Level     string
+	Message   string
+	Phase     string
+	Source    string
+}
+
+// deploymentReport loads a finished provider or APEX-hosted deployment the
+// user owns. Environment variables and provider config are never included.
+func (s *Service) deploymentReport(ctx context.Context, userID uint, deploymentID string) (*Report, error) {
+	db := s.db.WithContext(ctx)
+	var report *Report
+
+	var d deploy.Deployment
+	err := db.Where("id = ? AND user_id = ?", deploymentID, userID).First(&d).Error
+	switch {
+	case err == nil:
+		switch d.Status {
+		case deploy.StatusPending, deploy.StatusPreparing, deploy.StatusBuilding, deploy.StatusDeploying:
+			return nil, ErrNotEligible
+		}
+		projectID := d.ProjectID
+		started := d.CreatedAt
+		if d.StartedAt != nil {
+			started = *d.StartedAt
+		}
+		report = &Report{
+			Summary: Summary{
+				Kind: KindDeployment, TargetID: d.ID, UserID: userID, ProjectID: &projectID,
+				Status: string(d.Status), Error: d.ErrorMessage, StartedAt: started,
+				FinishedAt: d.CompletedAt, DurationMs: d.TotalTime,
+			},
+			Environment: map[string]any{
+				"provider":        string(d.Provider),
+				"environment":     d.Environment,
+				"branch":          d.Branch,
+				"commit_sha":      d.CommitSHA,
+				"build_time_ms":   d.BuildTime,
+				"deploy_time_ms":  d.DeployTime,
+				"deployment_url":  d.URL,
+				"preview_url":     d.PreviewURL,
+				"hosting_runtime": false,
+			},
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		var n hosting.NativeDeployment
+		err = db.Where("id = ? AND user_id = ?", deploymentID, userID).First(&n).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch n.Status {
+		case hosting.StatusPending, hosting.StatusProvisioning, hosting.StatusBuilding, hosting.StatusDeploying:
+			return nil, ErrNotEligible
+		}
+		projectID := n.ProjectID
+		finished := n.UpdatedAt
+		report = &Report{
+			Summary: Summary{
+				Kind: KindDeployment, TargetID: n.ID, UserID: userID, ProjectID: &projectID,
+				Status: string(n.Status), Error: n.ErrorMessage, StartedAt: n.CreatedAt,
+				FinishedAt: &finished, DurationMs: finished.Sub(n.CreatedAt).Milliseconds(),
+			},
+			Environment: map[string]any{
+				"provider":         "apex_hosting",
+				"container_status": string(n.ContainerStatus),
+				"framework":        n.Framework,
+				"node_version":     n.NodeVersion,
+				"python_version":   n.PythonVersion,
+				"go_version":       n.GoVersion,
+				"install_command":  n.InstallCommand,
+				"build_command":    n.BuildCommand,
+				"start_command":    n.StartCommand,
+				"container_port":   n.ContainerPort,
+				"memory_limit_mb":  n.MemoryLimit,
+				"cpu_limit_milli":  n.CPULimit,
+				"deployment_url":   n.URL,
+				"hosting_runtime":  true,
+			},
+		}
+	default:
+		return nil, err
+	}
+
+	if report.Summary.ProjectID != nil {
+		var name string
+		db.Table("projects").Select("name").Where("id = ?", *report.Summary.ProjectID).Scan(&name)
+		report.Summary.ProjectName = name
+	}
+
+	var logs []deploymentLogRow
+	if err := db.Table("deployment_logs").Where("deployment_id = ?", deploymentID).
+		Order("timestamp DESC, id DESC").Limit(maxTimelineEntries).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	validation := []TimelineEntry{}
+	report.Timeline = make([]TimelineEntry, 0, len(logs))
+	report.ProviderErrors = []TimelineEntry{}
+	for i := len(logs) - 1; i >= 0; i-- {
+		line := logs[i]
+		source := line.Phase
+		if source == "" {
+			source = line.Source
+		}
+		entry := TimelineEntry{Timestamp: line.Timestamp, Source: source, Level: line.Level, Message: line.Message}
+		report.Timeline = append(report.Timeline, entry)
+		level := strings.ToLower(line.Level)
+		if level == "error" {
+			report.ProviderErrors = append(report.ProviderErrors, entry)
+		}
+		if source == "build" && (level == "error" || level == "warn") {
+			validation = append(validation, entry)
+		}
+	}
+	report.Validation = map[string]any{"build_output": validation}
+	return report, nil
+}
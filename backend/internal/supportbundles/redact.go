@@ -0,0 +1,88 @@
+package supportbundles
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+var (
+	// secretPatterns match credential-shaped tokens anywhere in a string.
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{20,}`),                                    // Google / Gemini API keys
+		regexp.MustCompile(`\bxai-[0-9A-Za-z._\-]+`),                                       // xAI / Grok API keys
+		regexp.MustCompile(`\bsk-[0-9A-Za-z._\-]{8,}`),                                     // OpenAI / Anthropic / Stripe keys
+		regexp.MustCompile(`\b(?:gsk|rk|pk|whsec)_[0-9A-Za-z._\-]{8,}`),                    // misc provider and webhook keys
+		regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[0-9A-Za-z]{20,}`),                   // GitHub tokens
+		regexp.MustCompile(`\bgithub_pat_[0-9A-Za-z_]{20,}`),                               // GitHub fine-grained tokens
+		regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z\-]{10,}`),                              // Slack tokens
+		regexp.MustCompile(`\bAKIA[0-9A-Z]{16}`),                                           // AWS access key IDs
+		regexp.MustCompile(`\beyJ[0-9A-Za-z_\-]{8,}\.[0-9A-Za-z_\-]{8,}\.[0-9A-Za-z_\-]+`), // JWTs
+	}
+	// urlCredentialsPattern matches user:password@ in connection strings.
+	urlCredentialsPattern = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+(@)`)
+	// assignmentPattern matches secret-looking assignments such as
+	// `API_KEY=value` or `"password": "value"`.
+	assignmentPattern = regexp.MustCompile(`(?i)((?:[a-z0-9_]*(?:password|passwd|secret|token|api_?key|credential)[a-z0-9_]*)["']?\s*[:=]\s*["']?)[^\s"',;&]+`)
+	bearerPattern     = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._\-]+`)
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+)
+
+// sensitiveKeyFragments mark JSON keys whose values are dropped entirely.
+var sensitiveKeyFragments = []string{
+	"password", "passwd", "secret", "api_key", "apikey", "authorization", "cookie",
+	"credential", "private_key", "access_token", "refresh_token", "token_hash", "env_vars",
+}
+
+// Redact removes credentials, connection-string passwords and email
+// addresses from free text.
+func Redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	s = urlCredentialsPattern.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = assignmentPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = emailPattern.ReplaceAllString(s, "[email]")
+	return s
+}
+
+// redactTree redacts every string in a decoded JSON value and drops the
+// values of sensitive keys.
+func redactTree(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, nested := range value {
+			if isSensitiveKey(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = redactTree(nested)
+		}
+		return value
+	case []any:
+		for i, nested := range value {
+			value[i] = redactTree(nested)
+		}
+		return value
+	case string:
+		return Redact(value)
+	default:
+		return value
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(key), "-", "_"))
+	for _, fragment := range sensitiveKeyFragments {
+		if strings.Contains(normalized, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,394 @@
+// Package supportbundles assembles redacted diagnostics for a failed build or
+// deployment — its timeline, provider errors, validation output and
+// environment — into a zip archive. The archive is stored as a snapshot and
+// can be downloaded by its owner or, without account access, by anyone
+// holding the bundle's expiring support token.
+package supportbundles
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KindBuild      = "build"
+	KindDeployment = "deployment"
+
+	// TokenPrefix marks support tokens so they are recognizable in tickets.
+	TokenPrefix = "apxs_"
+	// DefaultTTL and MaxTTL bound how long a support token works.
+	DefaultTTL = 7 * 24 * time.Hour
+	MaxTTL     = 30 * 24 * time.Hour
+	// MaxBundlesPerUser caps stored bundles; the oldest are removed first.
+	MaxBundlesPerUser = 20
+	// maxTimelineEntries and maxEntryLength bound the archive size.
+	maxTimelineEntries = 500
+	maxEntryLength     = 2000
+)
+
+var (
+	ErrNotFound     = errors.New("support bundle target not found")
+	ErrInvalid      = errors.New("invalid support bundle request")
+	ErrNotEligible  = errors.New("diagnostics are available once the build or deployment has finished")
+	ErrInvalidToken = errors.New("invalid or expired support token")
+)
+
+// Bundle is a stored diagnostics archive. Only the token hash is kept.
+type Bundle struct {
+	ID               uint       `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	Kind             string     `gorm:"not null;size:20" json:"kind"`
+	TargetID         string     `gorm:"not null;size:64;index" json:"target_id"`
+	ProjectID        *uint      `gorm:"index" json:"project_id,omitempty"`
+	TargetStatus     string     `gorm:"size:32" json:"target_status"`
+	Summary          string     `gorm:"size:255" json:"summary,omitempty"`
+	TokenPrefix      string     `gorm:"size:16" json:"token_prefix"`
+	TokenHash        string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Archive          []byte     `json:"-"`
+	SizeBytes        int        `json:"size_bytes"`
+	ExpiresAt        time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	DownloadCount    int        `json:"download_count"`
+	LastDownloadedAt *time.Time `json:"last_downloaded_at,omitempty"`
+}
+
+// TableName keeps the table name explicit.
+func (Bundle) TableName() string { return "support_bundles" }
+
+// Summary identifies what the bundle describes and how it ended.
+type Summary struct {
+	Kind        string     `json:"kind"`
+	TargetID    string     `json:"target_id"`
+	UserID      uint       `json:"user_id"`
+	ProjectID   *uint      `json:"project_id,omitempty"`
+	ProjectName string     `json:"project_name,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	RootCause   string     `json:"root_cause,omitempty"`
+	CauseDetail string     `json:"cause_detail,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DurationMs  int64      `json:"duration_ms,omitempty"`
+}
+
+// TimelineEntry is one event or log line.
+type TimelineEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source,omitempty"` // agent role or log phase
+	Provider  string    `json:"provider,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Level     string    `json:"level,omitempty"`
+	Event     string    `json:"event,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// Report is everything a bundle contains before redaction.
+type Report struct {
+	Summary        Summary         `json:"summary"`
+	Timeline       []TimelineEntry `json:"timeline"`
+	ProviderErrors []TimelineEntry `json:"provider_errors"`
+	Validation     any             `json:"validation"`
+	Environment    map[string]any  `json:"environment"`
+}
+
+// BuildSource loads a finished build's diagnostics. Implemented by
+// *agents.AgentManager; it returns ErrNotFound for builds the user does not
+// own and ErrNotEligible for builds that are still running.
+type BuildSource interface {
+	SupportBundleReport(ctx context.Context, userID uint, buildID string) (*Report, error)
+}
+
+// CreateInput selects the build or deployment to bundle.
+type CreateInput struct {
+	Kind          string `json:"kind"`
+	TargetID      string `json:"target_id"`
+	ExpiresInDays int    `json:"expires_in_days,omitempty"`
+}
+
+// Service creates and serves support bundles.
+type Service struct {
+	db     *gorm.DB
+	builds BuildSource
+	now    func() time.Time
+}
+
+// NewService creates a support bundle Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// SetBuildSource enables build bundles.
+func (s *Service) SetBuildSource(builds BuildSource) {
+	s.builds = builds
+}
+
+// AutoMigrate creates the support bundle table.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Bundle{})
+}
+
+// Create assembles, redacts and stores a bundle. The plaintext support token
+// is returned once and cannot be recovered.
+func (s *Service) Create(ctx context.Context, userID uint, in CreateInput) (*Bundle, string, error) {
+	in.TargetID = strings.TrimSpace(in.TargetID)
+	if in.TargetID == "" || len(in.TargetID) > 64 {
+		return nil, "", fmt.Errorf("%w: target_id is required", ErrInvalid)
+	}
+	ttl := DefaultTTL
+	if in.ExpiresInDays != 0 {
+		ttl = time.Duration(in.ExpiresInDays) * 24 * time.Hour
+		if ttl <= 0 || ttl > MaxTTL {
+			return nil, "", fmt.Errorf("%w: expires_in_days must be between 1 and %d", ErrInvalid, int(MaxTTL/(24*time.Hour)))
+		}
+	}
+
+	var report *Report
+	var err error
+	switch in.Kind {
+	case KindBuild:
+		if s.builds == nil {
+			return nil, "", fmt.Errorf("%w: build diagnostics are not available", ErrInvalid)
+		}
+		report, err = s.builds.SupportBundleReport(ctx, userID, in.TargetID)
+	case KindDeployment:
+		report, err = s.deploymentReport(ctx, userID, in.TargetID)
+	default:
+		return nil, "", fmt.Errorf("%w: kind must be %q or %q", ErrInvalid, KindBuild, KindDeployment)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := TokenPrefix + hex.EncodeToString(raw)
+	now := s.now()
+	bundle := &Bundle{
+		UserID:       userID,
+		Kind:         in.Kind,
+		TargetID:     in.TargetID,
+		ProjectID:    report.Summary.ProjectID,
+		TargetStatus: report.Summary.Status,
+		Summary:      truncate(Redact(report.Summary.Error), 255),
+		TokenPrefix:  token[:len(TokenPrefix)+6],
+		TokenHash:    hashToken(token),
+		ExpiresAt:    now.Add(ttl),
+	}
+	archive, err := s.buildArchive(report, bundle, now)
+	if err != nil {
+		return nil, "", err
+	}
+	bundle.Archive = archive
+	bundle.SizeBytes = len(archive)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bundle).Error; err != nil {
+			return err
+		}
+		var keep []uint
+		if err := tx.Model(&Bundle{}).Where("user_id = ?", userID).Order("id DESC").
+			Limit(MaxBundlesPerUser).Pluck("id", &keep).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND id NOT IN ?", userID, keep).Delete(&Bundle{}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return bundle, token, nil
+}
+
+// List returns the user's bundles, newest first, without archives.
+func (s *Service) List(ctx context.Context, userID uint) ([]Bundle, error) {
+	bundles := []Bundle{}
+	err := s.db.WithContext(ctx).Omit("archive").Where("user_id = ?", userID).Order("id DESC").Find(&bundles).Error
+	return bundles, err
+}
+
+// Download returns one of the user's bundles with its archive.
+func (s *Service) Download(ctx context.Context, userID, bundleID uint) (*Bundle, error) {
+	var bundle Bundle
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", bundleID, userID).First(&bundle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &bundle, err
+}
+
+// Revoke stops a bundle's support token from working. The owner can still
+// download it.
+func (s *Service) Revoke(ctx context.Context, userID, bundleID uint) error {
+	result := s.db.WithContext(ctx).Model(&Bundle{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", bundleID, userID).
+		Update("revoked_at", s.now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// OpenByToken returns the bundle for a live support token and counts the
+// download.
+func (s *Service) OpenByToken(ctx context.Context, token string) (*Bundle, error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+	now := s.now()
+	var bundle Bundle
+	err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashToken(token), now).
+		First(&bundle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.db.WithContext(ctx).Model(&Bundle{}).Where("id = ?", bundle.ID).Updates(map[string]any{
+		"download_count":     gorm.Expr("download_count + 1"),
+		"last_downloaded_at": now,
+	})
+	return &bundle, nil
+}
+
+// FileName is the archive's download name.
+func (b *Bundle) FileName() string {
+	return fmt.Sprintf("apex-support-%s-%s.zip", b.Kind, safeName(b.TargetID))
+}
+
+// buildArchive redacts the report and writes one JSON file per section plus
+// a manifest.
+func (s *Service) buildArchive(report *Report, bundle *Bundle, now time.Time) ([]byte, error) {
+	if report.Environment == nil {
+		report.Environment = map[string]any{}
+	}
+	report.Environment["platform"] = platformEnvironment()
+	capTimeline(&report.Timeline)
+	capTimeline(&report.ProviderErrors)
+
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	if err := json.Unmarshal(encoded, &tree); err != nil {
+		return nil, err
+	}
+	redactTree(tree)
+
+	sections := []string{"summary", "timeline", "provider_errors", "validation", "environment"}
+	files := make([]string, 0, len(sections)+1)
+	for _, section := range sections {
+		files = append(files, section+".json")
+	}
+	manifest := map[string]any{
+		"kind":         bundle.Kind,
+		"target_id":    bundle.TargetID,
+		"generated_at": now,
+		"expires_at":   bundle.ExpiresAt,
+		"files":        files,
+		"redaction":    "Credentials, connection-string passwords, environment variable values and email addresses are removed.",
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		if err := writeJSON(zw, section+".json", tree[section]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	entry, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("supportbundles: write %s failed: %w", name, err)
+	}
+	enc := json.NewEncoder(entry)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("supportbundles: encode %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// platformEnvironment describes the server that produced the bundle.
+func platformEnvironment() map[string]any {
+	env := map[string]any{
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+	}
+	for key, name := range map[string]string{
+		"environment": "ENVIRONMENT",
+		"commit":      "RENDER_GIT_COMMIT",
+		"region":      "RENDER_REGION",
+		"instance":    "RENDER_INSTANCE_ID",
+	} {
+		if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// capTimeline keeps the newest entries and shortens long messages.
+func capTimeline(entries *[]TimelineEntry) {
+	if *entries == nil {
+		*entries = []TimelineEntry{}
+	}
+	if len(*entries) > maxTimelineEntries {
+		*entries = (*entries)[len(*entries)-maxTimelineEntries:]
+	}
+	for i := range *entries {
+		(*entries)[i].Message = truncate((*entries)[i].Message, maxEntryLength)
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
@@ -0,0 +1,85 @@
+package symbols
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Symbol kinds
+const (
+	KindFunction  = "function"
+	KindMethod    = "method"
+	KindClass     = "class"
+	KindInterface = "interface"
+	KindStruct    = "struct"
+	KindEnum      = "enum"
+	KindTrait     = "trait"
+	KindType      = "type"
+	KindConstant  = "constant"
+	KindVariable  = "variable"
+	KindModule    = "module"
+)
+
+// ErrUnsupported is returned by Extract for languages without a parser, and
+// for every language in builds without cgo.
+var ErrUnsupported = errors.New("symbol extraction is not supported for this language")
+
+// Extracted is one declaration found in a file.
+type Extracted struct {
+	Name      string
+	Kind      string
+	Container string // enclosing class, struct, trait, impl or module
+	Signature string
+	Line      int // 1-based
+	EndLine   int
+	Column    int // 1-based, of the name
+	Exported  bool
+}
+
+// languagesByExt maps file extensions to the parser languages
+var languagesByExt = map[string]string{
+	".go":   "go",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".ts":   "typescript",
+	".mts":  "typescript",
+	".cts":  "typescript",
+	".tsx":  "tsx",
+	".py":   "python",
+	".pyi":  "python",
+	".java": "java",
+	".rs":   "rust",
+	".c":    "c",
+	".h":    "c",
+	".php":  "php",
+}
+
+// LanguageForPath returns the parser language for a file, or "" when no
+// parser handles it.
+func LanguageForPath(path string) string {
+	return languagesByExt[strings.ToLower(filepath.Ext(path))]
+}
+
+// Extract parses content as language and returns its declarations in
+// source order. Function bodies are not descended into, so locals and
+// closures are left out.
+func Extract(language string, content []byte) ([]Extracted, error) {
+	return extract(language, content)
+}
+
+// signatureText trims a declaration's header to one line
+func signatureText(header string) string {
+	signature := strings.Join(strings.Fields(header), " ")
+	signature = strings.TrimRight(signature, " {:")
+	if len(signature) > 200 {
+		signature = signature[:200]
+		for !utf8.ValidString(signature) {
+			signature = signature[:len(signature)-1]
+		}
+	}
+	return signature
+}
@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package symbols
+
+func extract(language string, content []byte) ([]Extracted, error) {
+	_ = language
+	_ = content
+	return nil, ErrUnsupported
+}
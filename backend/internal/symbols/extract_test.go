@@ -0,0 +1,107 @@
+//go:build cgo
+
+package symbols
+
+import "testing"
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		language string
+		source   string
+		want     []Extracted // Name, Kind, Container, Line and Exported are compared
+	}{
+		{
+			language: "go",
+			source:   "package x\n\ntype Server struct{}\n\nfunc (s *Server) Start() error {\n\tinner := func() {}\n\treturn nil\n}\n\nconst MaxConns, minConns = 10, 1\n",
+			want: []Extracted{
+				{Name: "Server", Kind: KindStruct, Line: 3, Exported: true},
+				{Name: "Start", Kind: KindMethod, Container: "Server", Line: 5, Exported: true},
+				{Name: "MaxConns", Kind: KindConstant, Line: 10, Exported: true},
+				{Name: "minConns", Kind: KindConstant, Line: 10},
+			},
+		},
+		{
+			language: "python",
+			source:   "RETRIES = 3\n\nclass Client(Base):\n    def fetch(self):\n        def helper():\n            pass\n\ndef _private():\n    pass\n",
+			want: []Extracted{
+				{Name: "RETRIES", Kind: KindConstant, Line: 1, Exported: true},
+				{Name: "Client", Kind: KindClass, Line: 3, Exported: true},
+				{Name: "fetch", Kind: KindMethod, Container: "Client", Line: 4, Exported: true},
+				{Name: "_private", Kind: KindFunction, Line: 8},
+			},
+		},
+		{
+			language: "typescript",
+			source:   "export interface User { greet(): void }\nexport const handler = async (req: Request) => { const local = 1 }\nclass Store {\n  load(id: string) {}\n}\nnamespace Util { export function pad() {} }\n",
+			want: []Extracted{
+				{Name: "User", Kind: KindInterface, Line: 1, Exported: true},
+				{Name: "greet", Kind: KindMethod, Container: "User", Line: 1, Exported: true},
+				{Name: "handler", Kind: KindFunction, Line: 2, Exported: true},
+				{Name: "Store", Kind: KindClass, Line: 3},
+				{Name: "load", Kind: KindMethod, Container: "Store", Line: 4},
+				{Name: "Util", Kind: KindModule, Line: 6},
+				{Name: "pad", Kind: KindFunction, Container: "Util", Line: 6, Exported: true},
+			},
+		},
+		{
+			language: "java",
+			source:   "public class Account {\n  public Account() {}\n  private int balance() { return 0; }\n}\n",
+			want: []Extracted{
+				{Name: "Account", Kind: KindClass, Line: 1, Exported: true},
+				{Name: "Account", Kind: KindMethod, Container: "Account", Line: 2, Exported: true},
+				{Name: "balance", Kind: KindMethod, Container: "Account", Line: 3},
+			},
+		},
+		{
+			language: "rust",
+			source:   "pub struct Pool;\n\nimpl Pool {\n    pub fn new() -> Self { Pool }\n}\n\npub trait Run { fn run(&self); }\n",
+			want: []Extracted{
+				{Name: "Pool", Kind: KindStruct, Line: 1, Exported: true},
+				{Name: "new", Kind: KindMethod, Container: "Pool", Line: 4, Exported: true},
+				{Name: "Run", Kind: KindTrait, Line: 7, Exported: true},
+				{Name: "run", Kind: KindMethod, Container: "Run", Line: 7, Exported: true},
+			},
+		},
+		{
+			language: "c",
+			source:   "#define LIMIT 8\n\nstatic int clamp(int v) { return v; }\n\nint run(void);\n",
+			want: []Extracted{
+				{Name: "LIMIT", Kind: KindConstant, Line: 1, Exported: true},
+				{Name: "clamp", Kind: KindFunction, Line: 3},
+				{Name: "run", Kind: KindFunction, Line: 5, Exported: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			got, err := Extract(tt.language, []byte(tt.source))
+			if err != nil {
+				t.Fatalf("extract: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d symbols, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Name != want.Name || g.Kind != want.Kind || g.Container != want.Container || g.Line != want.Line || g.Exported != want.Exported {
+					t.Errorf("symbol %d = %+v, want %+v", i, g, want)
+				}
+			}
+		})
+	}
+
+	if _, err := Extract("cobol", []byte("IDENTIFICATION DIVISION.")); err != ErrUnsupported {
+		t.Fatalf("unsupported language err = %v", err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	got, err := Extract("go", []byte("package x\n\nfunc Sum(\n\ta int,\n\tb int,\n) int {\n\treturn a + b\n}\n"))
+	if err != nil || len(got) != 1 {
+		t.Fatalf("extract = %+v, %v", got, err)
+	}
+	if want := "func Sum( a int, b int, ) int"; got[0].Signature != want {
+		t.Fatalf("signature = %q, want %q", got[0].Signature, want)
+	}
+}
@@ -0,0 +1,505 @@
+//go:build cgo
+
+package symbols
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	_c "github.com/smacker/go-tree-sitter/c"
+	_golang "github.com/smacker/go-tree-sitter/golang"
+	_java "github.com/smacker/go-tree-sitter/java"
+	_javascript "github.com/smacker/go-tree-sitter/javascript"
+	_php "github.com/smacker/go-tree-sitter/php"
+	_python "github.com/smacker/go-tree-sitter/python"
+	_rust "github.com/smacker/go-tree-sitter/rust"
+	_tsx "github.com/smacker/go-tree-sitter/typescript/tsx"
+	_typescript "github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+var grammars = map[string]func() *sitter.Language{
+	"go":         _golang.GetLanguage,
+	"javascript": _javascript.GetLanguage,
+	"typescript": _typescript.GetLanguage,
+	"tsx":        _tsx.GetLanguage,
+	"python":     _python.GetLanguage,
+	"java":       _java.GetLanguage,
+	"rust":       _rust.GetLanguage,
+	"c":          _c.GetLanguage,
+	"php":        _php.GetLanguage,
+}
+
+// maxWalkDepth bounds recursion on pathological nesting
+const maxWalkDepth = 64
+
+func extract(language string, content []byte) ([]Extracted, error) {
+	grammar, ok := grammars[language]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	parser := sitter.NewParser()
+	parser.SetLanguage(grammar())
+	parser.SetOperationLimit(4_000_000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
+	defer cancel()
+
+	tree, err := parser.ParseCtx(ctx, nil, content)
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil || tree.RootNode() == nil {
+		return nil, fmt.Errorf("tree-sitter produced empty syntax tree")
+	}
+
+	w := &walker{language: language, source: content}
+	w.walk(tree.RootNode(), scope{}, 0)
+	return w.out, nil
+}
+
+// scope is what encloses the nodes being walked
+type scope struct {
+	container string
+	kind      string // the container's kind, or "impl" for Go receivers and Rust impl blocks
+	exported  bool   // inside a JS/TS export statement
+}
+
+type walker struct {
+	language string
+	source   []byte
+	out      []Extracted
+}
+
+func (w *walker) walk(node *sitter.Node, sc scope, depth int) {
+	if depth > maxWalkDepth {
+		return
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child == nil {
+			continue
+		}
+		if inner, descend := w.visit(child, sc); descend {
+			w.walk(child, inner, depth+1)
+		}
+	}
+}
+
+// visit records node if it declares a symbol and returns the scope for its
+// children, or false when they should be skipped
+func (w *walker) visit(node *sitter.Node, sc scope) (scope, bool) {
+	switch w.language {
+	case "go":
+		return w.visitGo(node, sc)
+	case "python":
+		return w.visitPython(node, sc)
+	case "javascript", "typescript", "tsx":
+		return w.visitJS(node, sc)
+	case "java":
+		return w.visitJava(node, sc)
+	case "rust":
+		return w.visitRust(node, sc)
+	case "c":
+		return w.visitC(node, sc)
+	case "php":
+		return w.visitPHP(node, sc)
+	}
+	return sc, false
+}
+
+// add records a declaration named by nameNode and returns its name, or ""
+// when it has none. Functions declared in a type become methods.
+func (w *walker) add(node, nameNode *sitter.Node, kind string, sc scope, exported bool) string {
+	if nameNode == nil {
+		return ""
+	}
+	name := strings.TrimSpace(nameNode.Content(w.source))
+	if name == "" {
+		return ""
+	}
+	if kind == KindFunction && containsMethods(sc.kind) {
+		kind = KindMethod
+	}
+	start, end := node.StartPoint(), node.EndPoint()
+	if end.Column == 0 && end.Row > start.Row {
+		end.Row-- // nodes that swallow their trailing newline, like #define
+	}
+	w.out = append(w.out, Extracted{
+		Name:      name,
+		Kind:      kind,
+		Container: sc.container,
+		Signature: w.signature(node),
+		Line:      int(start.Row) + 1,
+		EndLine:   int(end.Row) + 1,
+		Column:    int(nameNode.StartPoint().Column) + 1,
+		Exported:  exported,
+	})
+	return name
+}
+
+// nested is the scope for the members of a container declaration
+func nested(name, kind string, sc scope) scope {
+	return scope{container: name, kind: kind, exported: sc.exported}
+}
+
+func containsMethods(kind string) bool {
+	switch kind {
+	case KindClass, KindInterface, KindStruct, KindTrait, KindEnum, "impl":
+		return true
+	}
+	return false
+}
+
+// signature is the declaration up to its body, or its first line. For
+// variables bound to a function the function's body is used.
+func (w *walker) signature(node *sitter.Node) string {
+	end := node.EndByte()
+	body := node.ChildByFieldName("body")
+	if value := node.ChildByFieldName("value"); body == nil && value != nil {
+		body = value.ChildByFieldName("body")
+	}
+	if body != nil && body.StartByte() > node.StartByte() {
+		end = body.StartByte()
+	}
+	header := w.source[node.StartByte():end]
+	if body == nil {
+		if i := strings.IndexByte(string(header), '\n'); i >= 0 {
+			header = header[:i]
+		}
+	}
+	if len(header) > 1000 {
+		header = header[:1000]
+	}
+	return signatureText(strings.ToValidUTF8(string(header), ""))
+}
+
+func (w *walker) text(node *sitter.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Content(w.source)
+}
+
+// firstOfType finds the first descendant (or node itself) of one of types
+func firstOfType(node *sitter.Node, types ...string) *sitter.Node {
+	if node == nil {
+		return nil
+	}
+	for _, t := range types {
+		if node.Type() == t {
+			return node
+		}
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if found := firstOfType(node.NamedChild(i), types...); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func hasChildOfType(node *sitter.Node, t string) *sitter.Node {
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if child := node.NamedChild(i); child != nil && child.Type() == t {
+			return child
+		}
+	}
+	return nil
+}
+
+func upperFirst(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// upperSnake reports names like MAX_RETRIES, conventionally constants
+func upperSnake(name string) bool {
+	return name != "" && strings.ToUpper(name) == name && strings.ContainsFunc(name, unicode.IsLetter)
+}
+
+func (w *walker) visitGo(node *sitter.Node, sc scope) (scope, bool) {
+	switch node.Type() {
+	case "function_declaration":
+		name := node.ChildByFieldName("name")
+		w.add(node, name, KindFunction, sc, upperFirst(w.text(name)))
+		return sc, false
+	case "method_declaration":
+		name := node.ChildByFieldName("name")
+		receiver := w.text(firstOfType(node.ChildByFieldName("receiver"), "type_identifier"))
+		w.add(node, name, KindMethod, scope{container: receiver, kind: "impl"}, upperFirst(w.text(name)))
+		return sc, false
+	case "type_spec", "type_alias":
+		kind := KindType
+		switch typ := node.ChildByFieldName("type"); {
+		case typ == nil:
+		case typ.Type() == "struct_type":
+			kind = KindStruct
+		case typ.Type() == "interface_type":
+			kind = KindInterface
+		}
+		name := node.ChildByFieldName("name")
+		w.add(node, name, kind, sc, upperFirst(w.text(name)))
+		return sc, false
+	case "const_spec", "var_spec":
+		kind := KindVariable
+		if node.Type() == "const_spec" {
+			kind = KindConstant
+		}
+		for i := 0; i < int(node.ChildCount()); i++ {
+			if name := node.Child(i); name != nil && name.IsNamed() && node.FieldNameForChild(i) == "name" {
+				w.add(node, name, kind, sc, upperFirst(w.text(name)))
+			}
+		}
+		return sc, false
+	}
+	return sc, true
+}
+
+func (w *walker) visitPython(node *sitter.Node, sc scope) (scope, bool) {
+	switch node.Type() {
+	case "class_definition":
+		name := node.ChildByFieldName("name")
+		n := w.add(node, name, KindClass, sc, !strings.HasPrefix(w.text(name), "_"))
+		return nested(n, KindClass, sc), true
+	case "function_definition":
+		name := node.ChildByFieldName("name")
+		w.add(node, name, KindFunction, sc, !strings.HasPrefix(w.text(name), "_"))
+		return sc, false
+	case "expression_statement":
+		assignment := node.NamedChild(0)
+		if assignment == nil || assignment.Type() != "assignment" {
+			return sc, false
+		}
+		left := assignment.ChildByFieldName("left")
+		if left == nil || left.Type() != "identifier" {
+			return sc, false
+		}
+		kind := KindVariable
+		if upperSnake(w.text(left)) {
+			kind = KindConstant
+		}
+		w.add(node, left, kind, sc, !strings.HasPrefix(w.text(left), "_"))
+		return sc, false
+	case "lambda", "call":
+		return sc, false
+	}
+	return sc, true
+}
+
+func (w *walker) visitJS(node *sitter.Node, sc scope) (scope, bool) {
+	switch node.Type() {
+	case "export_statement":
+		sc.exported = true
+		return sc, true
+	case "function_declaration", "generator_function_declaration", "function_signature":
+		w.add(node, node.ChildByFieldName("name"), KindFunction, sc, sc.exported)
+		return sc, false
+	case "class_declaration", "abstract_class_declaration":
+		n := w.add(node, node.ChildByFieldName("name"), KindClass, sc, sc.exported)
+		return nested(n, KindClass, sc), true
+	case "method_definition", "method_signature", "abstract_method_signature":
+		w.add(node, node.ChildByFieldName("name"), KindMethod, sc, sc.exported)
+		return sc, false
+	case "interface_declaration":
+		n := w.add(node, node.ChildByFieldName("name"), KindInterface, sc, sc.exported)
+		return nested(n, KindInterface, sc), true
+	case "type_alias_declaration":
+		w.add(node, node.ChildByFieldName("name"), KindType, sc, sc.exported)
+		return sc, false
+	case "enum_declaration":
+		w.add(node, node.ChildByFieldName("name"), KindEnum, sc, sc.exported)
+		return sc, false
+	case "internal_module", "module":
+		n := w.add(node, node.ChildByFieldName("name"), KindModule, sc, sc.exported)
+		return nested(n, KindModule, sc), true
+	case "lexical_declaration", "variable_declaration":
+		if sc.kind != "" && sc.kind != KindModule {
+			return sc, false
+		}
+		isConst := strings.HasPrefix(w.text(node), "const")
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			declarator := node.NamedChild(i)
+			if declarator == nil || declarator.Type() != "variable_declarator" {
+				continue
+			}
+			name := declarator.ChildByFieldName("name")
+			if name == nil || name.Type() != "identifier" {
+				continue
+			}
+			kind := KindVariable
+			if isConst {
+				kind = KindConstant
+			}
+			if value := declarator.ChildByFieldName("value"); value != nil {
+				switch value.Type() {
+				case "arrow_function", "function", "function_expression", "generator_function":
+					kind = KindFunction
+				}
+			}
+			w.add(declarator, name, kind, sc, sc.exported)
+		}
+		return sc, false
+	case "statement_block":
+		// Only namespace bodies; function bodies are never entered
+		return sc, sc.kind == KindModule
+	case "arrow_function", "function", "function_expression", "call_expression":
+		return sc, false
+	}
+	return sc, true
+}
+
+func (w *walker) visitJava(node *sitter.Node, sc scope) (scope, bool) {
+	public := func() bool {
+		return strings.Contains(w.text(hasChildOfType(node, "modifiers")), "public") || sc.kind == KindInterface
+	}
+	switch node.Type() {
+	case "class_declaration", "record_declaration", "interface_declaration", "annotation_type_declaration", "enum_declaration":
+		kind := KindClass
+		switch node.Type() {
+		case "interface_declaration", "annotation_type_declaration":
+			kind = KindInterface
+		case "enum_declaration":
+			kind = KindEnum
+		}
+		n := w.add(node, node.ChildByFieldName("name"), kind, sc, public())
+		return nested(n, kind, sc), true
+	case "method_declaration", "constructor_declaration":
+		w.add(node, node.ChildByFieldName("name"), KindMethod, sc, public())
+		return sc, false
+	case "block", "lambda_expression", "field_declaration":
+		return sc, false
+	}
+	return sc, true
+}
+
+func (w *walker) visitRust(node *sitter.Node, sc scope) (scope, bool) {
+	public := hasChildOfType(node, "visibility_modifier") != nil
+	switch node.Type() {
+	case "function_item", "function_signature_item":
+		w.add(node, node.ChildByFieldName("name"), KindFunction, sc, public || sc.kind == KindTrait)
+		return sc, false
+	case "struct_item", "union_item", "enum_item", "type_item", "const_item", "static_item":
+		kind := map[string]string{
+			"struct_item": KindStruct, "union_item": KindStruct, "enum_item": KindEnum,
+			"type_item": KindType, "const_item": KindConstant, "static_item": KindConstant,
+		}[node.Type()]
+		w.add(node, node.ChildByFieldName("name"), kind, sc, public)
+		return sc, false
+	case "trait_item", "mod_item":
+		kind := KindTrait
+		if node.Type() == "mod_item" {
+			kind = KindModule
+		}
+		n := w.add(node, node.ChildByFieldName("name"), kind, sc, public)
+		return nested(n, kind, sc), true
+	case "impl_item":
+		typ := node.ChildByFieldName("type")
+		name := w.text(firstOfType(typ, "type_identifier"))
+		if name == "" {
+			name = w.text(typ)
+		}
+		return nested(name, "impl", sc), true
+	case "block", "closure_expression", "macro_definition", "macro_invocation":
+		return sc, false
+	}
+	return sc, true
+}
+
+// cDeclaratorName unwraps pointer, array and function declarators down to
+// the declared identifier
+func cDeclaratorName(d *sitter.Node) (*sitter.Node, bool) {
+	isFunction := false
+	for range 8 {
+		if d == nil {
+			return nil, isFunction
+		}
+		switch d.Type() {
+		case "identifier", "field_identifier", "type_identifier":
+			return d, isFunction
+		case "function_declarator":
+			isFunction = true
+		}
+		if inner := d.ChildByFieldName("declarator"); inner != nil {
+			d = inner
+		} else {
+			d = d.NamedChild(0)
+		}
+	}
+	return nil, isFunction
+}
+
+func (w *walker) visitC(node *sitter.Node, sc scope) (scope, bool) {
+	static := false
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if child := node.NamedChild(i); child != nil && child.Type() == "storage_class_specifier" && w.text(child) == "static" {
+			static = true
+		}
+	}
+	switch node.Type() {
+	case "function_definition":
+		name, _ := cDeclaratorName(node.ChildByFieldName("declarator"))
+		w.add(node, name, KindFunction, sc, !static)
+		return sc, false
+	case "declaration":
+		// Prototypes, as in headers; other declarations may define a struct
+		if name, isFunction := cDeclaratorName(node.ChildByFieldName("declarator")); isFunction {
+			w.add(node, name, KindFunction, sc, !static)
+			return sc, false
+		}
+		return sc, true
+	case "struct_specifier", "union_specifier", "enum_specifier":
+		if node.ChildByFieldName("body") != nil {
+			kind := KindStruct
+			if node.Type() == "enum_specifier" {
+				kind = KindEnum
+			}
+			w.add(node, node.ChildByFieldName("name"), kind, sc, true)
+		}
+		return sc, false
+	case "type_definition":
+		name, _ := cDeclaratorName(node.ChildByFieldName("declarator"))
+		w.add(node, name, KindType, sc, true)
+		return sc, false
+	case "preproc_def", "preproc_function_def":
+		kind := KindConstant
+		if node.Type() == "preproc_function_def" {
+			kind = KindFunction
+		}
+		w.add(node, node.ChildByFieldName("name"), kind, sc, true)
+		return sc, false
+	case "compound_statement":
+		return sc, false
+	}
+	return sc, true
+}
+
+func (w *walker) visitPHP(node *sitter.Node, sc scope) (scope, bool) {
+	switch node.Type() {
+	case "function_definition":
+		w.add(node, node.ChildByFieldName("name"), KindFunction, sc, true)
+		return sc, false
+	case "class_declaration", "interface_declaration", "trait_declaration", "enum_declaration":
+		kind := map[string]string{
+			"class_declaration": KindClass, "interface_declaration": KindInterface,
+			"trait_declaration": KindTrait, "enum_declaration": KindEnum,
+		}[node.Type()]
+		n := w.add(node, node.ChildByFieldName("name"), kind, sc, true)
+		return nested(n, kind, sc), true
+	case "method_declaration":
+		visibility := w.text(hasChildOfType(node, "visibility_modifier"))
+		w.add(node, node.ChildByFieldName("name"), KindMethod, sc, visibility != "private" && visibility != "protected")
+		return sc, false
+	case "namespace_definition":
+		n := w.add(node, node.ChildByFieldName("name"), KindModule, sc, true)
+		return nested(n, KindModule, sc), true
+	case "compound_statement", "anonymous_function_creation_expression", "arrow_function":
+		return sc, false
+	}
+	return sc, true
+}
@@ -0,0 +1,447 @@
+// Package symbols keeps a table of the declarations in each project file
+// (functions, classes, methods, types, constants and exports) extracted
+// with tree-sitter. Files are indexed when saved and swept in the
+// background; the table powers symbol search, file outlines and
+// go-to-symbol.
+package symbols
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultRefreshInterval is how often files changed outside the editor
+	// are picked up.
+	DefaultRefreshInterval = time.Minute
+
+	// maxParseBytes skips generated and vendored bundles; their symbols
+	// are noise in search results
+	maxParseBytes = 512 << 10
+
+	refreshBatchSize  = 100
+	refreshMaxBatches = 20
+	insertBatchSize   = 200
+
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+var (
+	// ErrFileNotFound is returned by Outline for unknown paths.
+	ErrFileNotFound = errors.New("file not found")
+)
+
+var (
+	indexedFilesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "apex",
+		Subsystem: "symbols",
+		Name:      "indexed_files_total",
+		Help:      "Files parsed for symbols, by result (ok, unsupported, error)",
+	}, []string{"result"})
+)
+
+// Symbol is one declaration in a file.
+type Symbol struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	ProjectID uint   `gorm:"not null;index:idx_file_symbols_project_name" json:"project_id"`
+	FileID    uint   `gorm:"not null;index" json:"file_id"`
+	FilePath  string `gorm:"not null" json:"file_path"`
+	Name      string `gorm:"not null;size:255;index:idx_file_symbols_project_name" json:"name"`
+	Kind      string `gorm:"not null;size:32" json:"kind"`
+	Container string `gorm:"size:255" json:"container,omitempty"`
+	Signature string `gorm:"size:255" json:"signature,omitempty"`
+	Line      int    `json:"line"`
+	EndLine   int    `json:"end_line"`
+	Column    int    `gorm:"column:name_column" json:"column"` // "column" is reserved in SQL
+	Exported  bool   `json:"exported"`
+	Language  string `gorm:"size:32" json:"language"`
+}
+
+func (Symbol) TableName() string { return "file_symbols" }
+
+// FileState records the content a file's symbols were extracted from, so
+// unchanged files are not parsed again. Every file gets one, including
+// those in unsupported languages.
+type FileState struct {
+	FileID          uint      `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	ProjectID       uint      `gorm:"not null;index" json:"project_id"`
+	ContentHash     string    `gorm:"size:64" json:"content_hash"`
+	SourceUpdatedAt time.Time `json:"source_updated_at"`
+	Language        string    `gorm:"size:32" json:"language"` // empty when no parser handles the file
+	SymbolCount     int       `json:"symbol_count"`
+	Error           string    `gorm:"size:500" json:"error,omitempty"`
+	IndexedAt       time.Time `json:"indexed_at"`
+}
+
+func (FileState) TableName() string { return "file_symbol_states" }
+
+// Query filters a symbol search.
+type Query struct {
+	ProjectID uint
+	Text      string   // case-insensitive substring of the name; empty matches all
+	Kinds     []string // empty matches all
+	FileID    uint     // optional
+	Limit     int
+}
+
+// OutlineNode is a symbol in a file outline, with the members declared in it.
+type OutlineNode struct {
+	Name      string         `json:"name"`
+	Kind      string         `json:"kind"`
+	Signature string         `json:"signature,omitempty"`
+	Line      int            `json:"line"`
+	EndLine   int            `json:"end_line"`
+	Column    int            `json:"column"`
+	Exported  bool           `json:"exported"`
+	Children  []*OutlineNode `json:"children,omitempty"`
+}
+
+// Service maintains the symbol table.
+type Service struct {
+	db  *gorm.DB
+	now func() time.Time
+
+	refreshMu sync.Mutex
+}
+
+// NewService creates a new Service backed by the given database.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, now: time.Now}
+}
+
+// AutoMigrate creates the symbol and file state tables.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Symbol{}, &FileState{})
+}
+
+// IndexFile re-extracts a file's symbols if its path or content changed
+// since it was last indexed. Directories, binary files and languages
+// without a parser are recorded with no symbols.
+func (s *Service) IndexFile(ctx context.Context, file *models.File) error {
+	if file == nil || file.ID == 0 {
+		return nil
+	}
+	hash := contentHash(file)
+
+	var existing []FileState
+	if err := s.db.WithContext(ctx).Where("file_id = ?", file.ID).Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("symbols: load state for file %d: %w", file.ID, err)
+	}
+	if len(existing) == 1 && existing[0].ContentHash == hash {
+		if !existing[0].SourceUpdatedAt.Equal(file.UpdatedAt) {
+			return s.db.WithContext(ctx).Model(&FileState{}).Where("file_id = ?", file.ID).
+				Update("source_updated_at", file.UpdatedAt).Error
+		}
+		return nil
+	}
+
+	language := LanguageForPath(file.Path)
+	if file.Type == "directory" || file.IsBinary || len(file.Content) > maxParseBytes {
+		language = ""
+	}
+	var extracted []Extracted
+	var parseErr error
+	if language != "" {
+		extracted, parseErr = Extract(language, []byte(file.Content))
+		if errors.Is(parseErr, ErrUnsupported) {
+			language, parseErr = "", nil
+		}
+	}
+
+	state := FileState{
+		FileID:          file.ID,
+		ProjectID:       file.ProjectID,
+		ContentHash:     hash,
+		SourceUpdatedAt: file.UpdatedAt,
+		Language:        language,
+		SymbolCount:     len(extracted),
+		IndexedAt:       s.now(),
+	}
+	result := "ok"
+	switch {
+	case parseErr != nil:
+		state.Error = truncate(parseErr.Error(), 500)
+		state.SymbolCount = 0
+		extracted = nil
+		result = "error"
+	case language == "":
+		result = "unsupported"
+	}
+
+	rows := make([]Symbol, 0, len(extracted))
+	for _, e := range extracted {
+		rows = append(rows, Symbol{
+			ProjectID: file.ProjectID,
+			FileID:    file.ID,
+			FilePath:  file.Path,
+			Name:      truncate(e.Name, 255),
+			Kind:      e.Kind,
+			Container: truncate(e.Container, 255),
+			Signature: e.Signature,
+			Line:      e.Line,
+			EndLine:   e.EndLine,
+			Column:    e.Column,
+			Exported:  e.Exported,
+			Language:  language,
+		})
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.ID).Delete(&Symbol{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, insertBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "file_id"}},
+			UpdateAll: true,
+		}).Create(&state).Error
+	})
+	if err != nil {
+		return fmt.Errorf("symbols: index file %d: %w", file.ID, err)
+	}
+	indexedFilesTotal.WithLabelValues(result).Inc()
+	return nil
+}
+
+// RemoveFile drops a deleted file's symbols.
+func (s *Service) RemoveFile(ctx context.Context, fileID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", fileID).Delete(&Symbol{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("file_id = ?", fileID).Delete(&FileState{}).Error
+	})
+}
+
+// Refresh indexes files that are new or changed since they were last
+// indexed, such as those written by builds, imports and git, and drops the
+// symbols of deleted files. It returns how many files were examined.
+func (s *Service) Refresh(ctx context.Context) (int, error) {
+	if !s.refreshMu.TryLock() {
+		return 0, nil
+	}
+	defer s.refreshMu.Unlock()
+
+	examined := 0
+	for range refreshMaxBatches {
+		var files []models.File
+		err := s.db.WithContext(ctx).Table("files AS f").Select("f.*").
+			Joins("LEFT JOIN file_symbol_states s ON s.file_id = f.id").
+			Where("f.deleted_at IS NULL AND (s.file_id IS NULL OR s.source_updated_at <> f.updated_at)").
+			Order("f.id").Limit(refreshBatchSize).Find(&files).Error
+		if err != nil {
+			return examined, fmt.Errorf("symbols: find changed files: %w", err)
+		}
+		for i := range files {
+			if err := s.IndexFile(ctx, &files[i]); err != nil {
+				return examined, err
+			}
+		}
+		examined += len(files)
+		if len(files) < refreshBatchSize || ctx.Err() != nil {
+			break
+		}
+	}
+
+	gone := "NOT EXISTS (SELECT 1 FROM files f WHERE f.id = %s.file_id AND f.deleted_at IS NULL)"
+	if err := s.db.WithContext(ctx).Where(fmt.Sprintf(gone, "file_symbols")).Delete(&Symbol{}).Error; err != nil {
+		return examined, fmt.Errorf("symbols: drop deleted files: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Where(fmt.Sprintf(gone, "file_symbol_states")).Delete(&FileState{}).Error; err != nil {
+		return examined, fmt.Errorf("symbols: drop deleted files: %w", err)
+	}
+	return examined, nil
+}
+
+// Search finds symbols by name: exact matches first, then prefixes, then
+// substrings, with exported symbols and shorter names ahead.
+func (s *Service) Search(ctx context.Context, query Query) ([]Symbol, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	db := s.db.WithContext(ctx).Where("project_id = ?", query.ProjectID)
+	if query.FileID != 0 {
+		db = db.Where("file_id = ?", query.FileID)
+	}
+	if len(query.Kinds) > 0 {
+		db = db.Where("kind IN ?", query.Kinds)
+	}
+	order := clause.Expr{SQL: "exported DESC, LENGTH(name), name, file_path, line"}
+	text := strings.ToLower(strings.TrimSpace(query.Text))
+	if text != "" {
+		escaped := escapeLike(text)
+		db = db.Where("LOWER(name) LIKE ? ESCAPE '\\'", "%"+escaped+"%")
+		order = clause.Expr{
+			SQL:  "CASE WHEN LOWER(name) = ? THEN 0 WHEN LOWER(name) LIKE ? ESCAPE '\\' THEN 1 ELSE 2 END, " + order.SQL,
+			Vars: []any{text, escaped + "%"},
+		}
+	}
+
+	var symbols []Symbol
+	err := db.Clauses(clause.OrderBy{Expression: order}).Limit(limit).Find(&symbols).Error
+	if err != nil {
+		return nil, fmt.Errorf("symbols: search: %w", err)
+	}
+	return symbols, nil
+}
+
+// Definitions returns the declarations named exactly as one of names,
+// exported ones first. Completions use it to show the model the types and
+// signatures of identifiers near the cursor.
+func (s *Service) Definitions(ctx context.Context, projectID uint, names []string, limit int) ([]Symbol, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	var symbols []Symbol
+	err := s.db.WithContext(ctx).
+		Where("project_id = ? AND name IN ?", projectID, names).
+		Order("exported DESC, file_path, line").
+		Limit(limit).Find(&symbols).Error
+	if err != nil {
+		return nil, fmt.Errorf("symbols: definitions: %w", err)
+	}
+	return symbols, nil
+}
+
+// Outline returns the symbols of one file nested by container, indexing
+// the file first if it changed since it was last indexed.
+func (s *Service) Outline(ctx context.Context, projectID uint, path string) ([]*OutlineNode, error) {
+	var file models.File
+	err := s.db.WithContext(ctx).Where("project_id = ? AND path = ?", projectID, path).Take(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("symbols: load file: %w", err)
+	}
+	if err := s.IndexFile(ctx, &file); err != nil {
+		return nil, err
+	}
+
+	var symbols []Symbol
+	if err := s.db.WithContext(ctx).Where("file_id = ?", file.ID).Order("line, name_column").Find(&symbols).Error; err != nil {
+		return nil, fmt.Errorf("symbols: load outline: %w", err)
+	}
+	return buildOutline(symbols), nil
+}
+
+// CoveredFiles returns the project files whose symbols come from a parser.
+// Files in other languages, or that failed to parse, are not covered.
+func (s *Service) CoveredFiles(ctx context.Context, projectID uint) (map[uint]bool, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).Model(&FileState{}).
+		Where("project_id = ? AND language <> '' AND (error = '' OR error IS NULL)", projectID).
+		Pluck("file_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("symbols: load covered files: %w", err)
+	}
+	covered := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		covered[id] = true
+	}
+	return covered, nil
+}
+
+// Start refreshes the symbol table on a ticker until ctx is cancelled.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Refresh(ctx); err != nil {
+					log.Printf("symbols: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// buildOutline nests symbols, sorted by position, under the class, type or
+// module that contains them
+func buildOutline(symbols []Symbol) []*OutlineNode {
+	var roots []*OutlineNode
+	var stack []*OutlineNode
+	for _, sym := range symbols {
+		node := &OutlineNode{
+			Name:      sym.Name,
+			Kind:      sym.Kind,
+			Signature: sym.Signature,
+			Line:      sym.Line,
+			EndLine:   sym.EndLine,
+			Column:    sym.Column,
+			Exported:  sym.Exported,
+		}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if sym.Container == top.Name && sym.Line <= top.EndLine {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			top.Children = append(top.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+		switch sym.Kind {
+		case KindClass, KindInterface, KindStruct, KindEnum, KindTrait, KindModule:
+			stack = append(stack, node)
+		}
+	}
+	return roots
+}
+
+// contentHash identifies what a file's symbols were extracted from
+func contentHash(file *models.File) string {
+	sum := sha256.New()
+	sum.Write([]byte(file.Path))
+	sum.Write([]byte{0})
+	sum.Write([]byte(file.Content))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
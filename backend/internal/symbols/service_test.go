@@ -0,0 +1,128 @@
+package symbols
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.File{}); err != nil {
+		t.Fatalf("migrate files: %v", err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate symbols: %v", err)
+	}
+	return NewService(db), db
+}
+
+func TestServiceIndexesSearchesAndOutlines(t *testing.T) {
+	service, db := newTestService(t)
+	ctx := context.Background()
+
+	files := []models.File{
+		{ProjectID: 1, Path: "server.go", Name: "server.go", Type: "file", Content: "package main\n\ntype Server struct{}\n\nfunc (s *Server) HandleLogin() {}\n\nfunc handleLogout() {}\n"},
+		{ProjectID: 1, Path: "auth.py", Name: "auth.py", Type: "file", Content: "class Login:\n    def handle(self):\n        pass\n\ndef login_required(fn):\n    return fn\n"},
+		{ProjectID: 1, Path: "README.md", Name: "README.md", Type: "file", Content: "# handleLogin\n"},
+		{ProjectID: 2, Path: "other.go", Name: "other.go", Type: "file", Content: "package other\n\nfunc Login() {}\n"},
+	}
+	for i := range files {
+		if err := db.Create(&files[i]).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+
+	examined, err := service.Refresh(ctx)
+	if err != nil || examined != len(files) {
+		t.Fatalf("refresh = %d, %v", examined, err)
+	}
+	if examined, err = service.Refresh(ctx); err != nil || examined != 0 {
+		t.Fatalf("second refresh = %d, %v; want nothing pending", examined, err)
+	}
+
+	results, err := service.Search(ctx, Query{ProjectID: 1, Text: "login"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	var names []string
+	for _, sym := range results {
+		names = append(names, sym.Name)
+	}
+	want := []string{"Login", "login_required", "HandleLogin"}
+	if len(names) != len(want) {
+		t.Fatalf("search names = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("search names = %v, want %v", names, want)
+		}
+	}
+	if results[2].Kind != KindMethod || results[2].Container != "Server" || results[2].Line != 5 {
+		t.Fatalf("HandleLogin = %+v", results[2])
+	}
+
+	methods, err := service.Search(ctx, Query{ProjectID: 1, Kinds: []string{KindMethod}})
+	if err != nil || len(methods) != 2 {
+		t.Fatalf("methods = %+v, %v", methods, err)
+	}
+
+	covered, err := service.CoveredFiles(ctx, 1)
+	if err != nil {
+		t.Fatalf("covered: %v", err)
+	}
+	if !covered[files[0].ID] || !covered[files[1].ID] || covered[files[2].ID] {
+		t.Fatalf("covered = %v", covered)
+	}
+
+	outline, err := service.Outline(ctx, 1, "auth.py")
+	if err != nil {
+		t.Fatalf("outline: %v", err)
+	}
+	if len(outline) != 2 || outline[0].Name != "Login" || len(outline[0].Children) != 1 || outline[0].Children[0].Name != "handle" {
+		t.Fatalf("outline = %+v", outline)
+	}
+	if _, err := service.Outline(ctx, 1, "missing.go"); err != ErrFileNotFound {
+		t.Fatalf("missing outline err = %v", err)
+	}
+
+	// A save re-extracts the file
+	files[0].Content = "package main\n\nfunc Serve() {}\n"
+	files[0].UpdatedAt = time.Now().Add(time.Minute)
+	if err := db.Save(&files[0]).Error; err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := service.IndexFile(ctx, &files[0]); err != nil {
+		t.Fatalf("index file: %v", err)
+	}
+	if results, _ := service.Search(ctx, Query{ProjectID: 1, Text: "handlelogin"}); len(results) != 0 {
+		t.Fatalf("stale symbols after save: %+v", results)
+	}
+
+	// Deleted files lose their symbols on the next sweep
+	if err := db.Delete(&files[1]).Error; err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := service.Refresh(ctx); err != nil {
+		t.Fatalf("refresh after delete: %v", err)
+	}
+	var remaining int64
+	db.Model(&Symbol{}).Where("file_id = ?", files[1].ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("%d symbols left for deleted file", remaining)
+	}
+}
@@ -0,0 +1,253 @@
+// Package templates - Project provisioning from templates
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// SecretSealer encrypts secret values for storage. Implemented by
+// *secrets.SecretsManager.
+type SecretSealer interface {
+	Encrypt(userID uint, value string) (encryptedValue, saltBase64, keyFingerprint string, err error)
+}
+
+// CreateProject creates a private project owned by ownerID with the
+// template's files, plus a package.json and .env.example when the template
+// declares dependencies or environment variables. Variables take their
+// defaults and no secrets are created. It returns the project and the
+// number of files written.
+func CreateProject(db *gorm.DB, ownerID uint, template *Template, name, description string) (*models.Project, int, error) {
+	resolved, err := template.resolveVariables(nil, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	return createProject(db, ownerID, template, name, description, resolved, nil)
+}
+
+// CreateProjectWithVariables creates a project like CreateProject after
+// validating values against the template's variables. String and enum
+// values are substituted into {{apex.NAME}} references and written to a
+// .env file. Secret variables become encrypted project secrets; blank ones
+// get a generated value or an empty placeholder for the owner to fill in.
+// A VariableErrors is returned when values do not validate.
+func CreateProjectWithVariables(db *gorm.DB, sealer SecretSealer, ownerID uint, template *Template, name, description string, values map[string]string) (*models.Project, int, *ResolvedVariables, error) {
+	if sealer == nil {
+		return nil, 0, nil, fmt.Errorf("template variables need a secret sealer")
+	}
+	resolved, err := template.ResolveVariables(values)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	project, filesCount, err := createProject(db, ownerID, template, name, description, resolved, sealer)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return project, filesCount, resolved, nil
+}
+
+// createProject writes the project. The .env file and project secrets are
+// only added with a sealer.
+func createProject(db *gorm.DB, ownerID uint, template *Template, name, description string, resolved *ResolvedVariables, sealer SecretSealer) (*models.Project, int, error) {
+	now := time.Now()
+	project := &models.Project{
+		OwnerID:     ownerID,
+		Name:        name,
+		Description: description,
+		Language:    template.Language,
+		Framework:   template.Framework,
+		IsPublic:    false,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	newFile := func(path, content, mimeType string) models.File {
+		return models.File{
+			Name:      getFileName(path),
+			Type:      "file",
+			Path:      path,
+			Content:   content,
+			MimeType:  mimeType,
+			Size:      int64(len(content)),
+			Version:   1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+
+	var files []models.File
+	for _, tf := range template.Files {
+		files = append(files, newFile(tf.Path, resolved.Apply(tf.Content), getMimeType(tf.Path)))
+	}
+	if len(template.Dependencies) > 0 || len(template.DevDependencies) > 0 {
+		files = append(files, newFile("package.json", generatePackageJSON(name, template), "application/json"))
+	}
+	if len(template.EnvVars) > 0 {
+		files = append(files, newFile(".env.example", generateEnvExample(template.EnvVars), "text/plain"))
+		if sealer != nil {
+			files = append(files, newFile(".env", resolved.envFile(template.EnvVars), "text/plain"))
+		}
+	}
+
+	var projectSecrets []secrets.Secret
+	if sealer != nil {
+		for _, rs := range resolved.Secrets {
+			encrypted, salt, fingerprint, err := sealer.Encrypt(ownerID, rs.Value)
+			if err != nil {
+				return nil, 0, fmt.Errorf("encrypt %s: %w", rs.Name, err)
+			}
+			secretDescription := rs.Description
+			if rs.Placeholder {
+				secretDescription = strings.TrimSpace(secretDescription + " " + secrets.PlaceholderNote)
+			}
+			projectSecrets = append(projectSecrets, secrets.Secret{
+				UserID:         ownerID,
+				Name:           rs.Name,
+				Description:    secretDescription,
+				Type:           secrets.SecretTypeEnvironment,
+				IsPlaceholder:  rs.Placeholder,
+				EncryptedValue: encrypted,
+				Salt:           salt,
+				KeyFingerprint: fingerprint,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			})
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(project).Error; err != nil {
+			return err
+		}
+		if len(projectSecrets) > 0 {
+			for i := range projectSecrets {
+				projectSecrets[i].ProjectID = &project.ID
+			}
+			if err := tx.Create(&projectSecrets).Error; err != nil {
+				return err
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		for i := range files {
+			files[i].ProjectID = project.ID
+		}
+		return tx.Create(&files).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return project, len(files), nil
+}
+
+func getFileName(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+func getMimeType(path string) string {
+	ext := ""
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '.' {
+			ext = path[i:]
+			break
+		}
+	}
+
+	mimeTypes := map[string]string{
+		".js":   "text/javascript",
+		".ts":   "text/typescript",
+		".tsx":  "text/typescript",
+		".jsx":  "text/javascript",
+		".json": "application/json",
+		".html": "text/html",
+		".css":  "text/css",
+		".md":   "text/markdown",
+		".py":   "text/x-python",
+		".go":   "text/x-go",
+		".vue":  "text/x-vue",
+		".yaml": "text/yaml",
+		".yml":  "text/yaml",
+		".toml": "text/toml",
+		".sql":  "text/x-sql",
+		".sh":   "text/x-sh",
+		".txt":  "text/plain",
+	}
+
+	if mime, ok := mimeTypes[ext]; ok {
+		return mime
+	}
+	return "text/plain"
+}
+
+func generatePackageJSON(name string, template *Template) string {
+	deps := "{\n"
+	first := true
+	for pkg, version := range template.Dependencies {
+		if !first {
+			deps += ",\n"
+		}
+		deps += "    \"" + pkg + "\": \"" + version + "\""
+		first = false
+	}
+	deps += "\n  }"
+
+	devDeps := "{\n"
+	first = true
+	for pkg, version := range template.DevDependencies {
+		if !first {
+			devDeps += ",\n"
+		}
+		devDeps += "    \"" + pkg + "\": \"" + version + "\""
+		first = false
+	}
+	devDeps += "\n  }"
+
+	scripts := "{\n"
+	first = true
+	for cmd, script := range template.Scripts {
+		if !first {
+			scripts += ",\n"
+		}
+		scripts += "    \"" + cmd + "\": \"" + script + "\""
+		first = false
+	}
+	scripts += "\n  }"
+
+	return `{
+  "name": "` + name + `",
+  "version": "1.0.0",
+  "description": "Created with APEX.BUILD",
+  "scripts": ` + scripts + `,
+  "dependencies": ` + deps + `,
+  "devDependencies": ` + devDeps + `
+}
+`
+}
+
+func generateEnvExample(envVars []EnvVar) string {
+	content := "# Environment Variables\n# Copy this file to .env and fill in your values\n\n"
+	for _, ev := range envVars {
+		content += "# " + ev.Description + "\n"
+		if ev.Required {
+			content += "# (Required)\n"
+		}
+		if ev.Default != "" {
+			content += ev.Name + "=" + ev.Default + "\n\n"
+		} else {
+			content += ev.Name + "=\n\n"
+		}
+	}
+	return content
+}
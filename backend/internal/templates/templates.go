@@ -50,12 +50,16 @@ type TemplateFile struct {
 	IsEntry bool   `json:"is_entry,omitempty"`
 }
 
-// EnvVar represents an environment variable needed by the template
+// EnvVar represents an environment variable needed by the template. Each
+// one is a template variable the user fills in when creating a project.
 type EnvVar struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Required    bool   `json:"required"`
-	Default     string `json:"default,omitempty"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Type        VariableType `json:"type,omitempty"` // string (default), secret, enum
+	Required    bool         `json:"required"`
+	Default     string       `json:"default,omitempty"`
+	Options     []string     `json:"options,omitempty"`  // enum values
+	Generate    bool         `json:"generate,omitempty"` // secrets left blank get a random value
 }
 
 // GetAllTemplates returns all available project templates
@@ -196,7 +200,7 @@ func GetAllTemplates() []Template {
 			},
 			EnvVars: []EnvVar{
 				{Name: "PORT", Description: "Server port", Default: "3000"},
-				{Name: "NODE_ENV", Description: "Environment", Default: "development"},
+				{Name: "NODE_ENV", Description: "Environment", Type: VariableEnum, Options: []string{"development", "production"}, Default: "development"},
 			},
 		},
 		{
@@ -212,7 +216,7 @@ func GetAllTemplates() []Template {
 			Popular:     true,
 			Files:       getFastAPIFiles(),
 			EnvVars: []EnvVar{
-				{Name: "DATABASE_URL", Description: "Database connection string", Required: true},
+				{Name: "DATABASE_URL", Description: "Database connection string", Type: VariableSecret, Required: true},
 			},
 		},
 		{
@@ -263,8 +267,8 @@ func GetAllTemplates() []Template {
 			Popular:     true,
 			Files:       getMERNStackFiles(),
 			EnvVars: []EnvVar{
-				{Name: "MONGODB_URI", Description: "MongoDB connection string", Required: true},
-				{Name: "JWT_SECRET", Description: "JWT signing secret", Required: true},
+				{Name: "MONGODB_URI", Description: "MongoDB connection string", Type: VariableSecret, Required: true},
+				{Name: "JWT_SECRET", Description: "JWT signing secret", Type: VariableSecret, Required: true, Generate: true},
 			},
 		},
 
@@ -298,7 +302,7 @@ func GetAllTemplates() []Template {
 				"dotenv":     "^16.0.0",
 			},
 			EnvVars: []EnvVar{
-				{Name: "DISCORD_TOKEN", Description: "Discord bot token", Required: true},
+				{Name: "DISCORD_TOKEN", Description: "Discord bot token", Type: VariableSecret, Required: true},
 				{Name: "CLIENT_ID", Description: "Discord application client ID", Required: true},
 			},
 		},
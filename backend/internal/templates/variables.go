@@ -0,0 +1,229 @@
+// Package templates - Template variables and secret references
+package templates
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VariableType is how a template variable is prompted for and stored
+type VariableType string
+
+const (
+	// VariableString is plain text written to the project's .env
+	VariableString VariableType = "string"
+	// VariableSecret is stored as an encrypted project secret and never
+	// written to files
+	VariableSecret VariableType = "secret"
+	// VariableEnum is one of the variable's options, written to .env
+	VariableEnum VariableType = "enum"
+)
+
+// maxVariableLength caps a single variable value
+const maxVariableLength = 4096
+
+// VariableErrors maps variable names to what is wrong with their values
+type VariableErrors map[string]string
+
+func (e VariableErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+": "+e[name])
+	}
+	return "invalid template variables: " + strings.Join(parts, "; ")
+}
+
+// VariablePrompt describes one variable for the create-project form
+type VariablePrompt struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Type        VariableType `json:"type"`
+	// Input is the form control to render: text, password or select
+	Input    string   `json:"input"`
+	Required bool     `json:"required"`
+	Default  string   `json:"default,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	// Optional is true when the field may be left blank even though the
+	// variable is required: secrets get a generated value or a placeholder
+	Optional  bool `json:"optional"`
+	Generated bool `json:"generated,omitempty"`
+}
+
+// ResolvedSecret is a secret variable to create in the new project
+type ResolvedSecret struct {
+	Name        string
+	Description string
+	Value       string
+	// Placeholder is true when no value was given; the secret is created
+	// empty so the project lists it for the owner to fill in
+	Placeholder bool
+	Generated   bool
+}
+
+// ResolvedVariables are a template's variables after validation
+type ResolvedVariables struct {
+	// Values holds string and enum variables
+	Values  map[string]string
+	Secrets []ResolvedSecret
+}
+
+func (v EnvVar) variableType() VariableType {
+	if v.Type == "" {
+		return VariableString
+	}
+	return v.Type
+}
+
+// PromptSchema describes the template's variables for the create-project
+// form. Secret defaults are never exposed.
+func (t *Template) PromptSchema() []VariablePrompt {
+	prompts := make([]VariablePrompt, 0, len(t.EnvVars))
+	for _, v := range t.EnvVars {
+		p := VariablePrompt{
+			Name:        v.Name,
+			Description: v.Description,
+			Type:        v.variableType(),
+			Input:       "text",
+			Required:    v.Required,
+			Default:     v.Default,
+			Options:     v.Options,
+			Optional:    !v.Required || v.Default != "",
+		}
+		switch p.Type {
+		case VariableSecret:
+			p.Input = "password"
+			p.Default = ""
+			p.Optional = true
+			p.Generated = v.Generate
+		case VariableEnum:
+			p.Input = "select"
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts
+}
+
+// ResolveVariables validates values against the template's variables and
+// fills in defaults. Required string and enum variables need a value or a
+// default; blank secrets are generated or left as placeholders.
+func (t *Template) ResolveVariables(values map[string]string) (*ResolvedVariables, error) {
+	return t.resolveVariables(values, true)
+}
+
+func (t *Template) resolveVariables(values map[string]string, strict bool) (*ResolvedVariables, error) {
+	resolved := &ResolvedVariables{Values: map[string]string{}}
+	errs := VariableErrors{}
+	declared := make(map[string]bool, len(t.EnvVars))
+	for _, v := range t.EnvVars {
+		declared[v.Name] = true
+		value := values[v.Name]
+		if strings.ContainsAny(value, "\r\n") {
+			errs[v.Name] = "must be a single line"
+			continue
+		}
+		if len(value) > maxVariableLength {
+			errs[v.Name] = fmt.Sprintf("must be at most %d characters", maxVariableLength)
+			continue
+		}
+
+		switch v.variableType() {
+		case VariableSecret:
+			secret := ResolvedSecret{Name: v.Name, Description: v.Description, Value: value}
+			if value == "" {
+				if v.Generate {
+					generated, err := generateSecretValue()
+					if err != nil {
+						return nil, err
+					}
+					secret.Value = generated
+					secret.Generated = true
+				} else {
+					secret.Placeholder = true
+				}
+			}
+			resolved.Secrets = append(resolved.Secrets, secret)
+			continue
+		case VariableEnum:
+			if value != "" && !containsString(v.Options, value) {
+				errs[v.Name] = "must be one of " + strings.Join(v.Options, ", ")
+				continue
+			}
+		}
+		if value == "" {
+			value = v.Default
+		}
+		if value == "" && v.Required && strict {
+			errs[v.Name] = "is required"
+			continue
+		}
+		resolved.Values[v.Name] = value
+	}
+	if strict {
+		for name := range values {
+			if !declared[name] {
+				errs[name] = "is not a variable of this template"
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return resolved, nil
+}
+
+// Apply substitutes {{apex.NAME}} references to string and enum variables
+// in content. Secrets are never substituted; code reads them from the
+// environment.
+func (r *ResolvedVariables) Apply(content string) string {
+	if r == nil || len(r.Values) == 0 || !strings.Contains(content, "apex.") {
+		return content
+	}
+	pairs := make([]string, 0, len(r.Values)*4)
+	for name, value := range r.Values {
+		pairs = append(pairs, "{{apex."+name+"}}", value, "{{ apex."+name+" }}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(content)
+}
+
+// envFile renders the project's .env: variable values, with secrets noted
+// as coming from project secrets.
+func (r *ResolvedVariables) envFile(envVars []EnvVar) string {
+	var sb strings.Builder
+	sb.WriteString("# Environment Variables\n# Secrets are stored as project secrets and injected at run time\n\n")
+	for _, ev := range envVars {
+		if ev.variableType() == VariableSecret {
+			sb.WriteString("# " + ev.Name + " is a project secret\n\n")
+			continue
+		}
+		if ev.Description != "" {
+			sb.WriteString("# " + ev.Description + "\n")
+		}
+		sb.WriteString(ev.Name + "=" + r.Values[ev.Name] + "\n\n")
+	}
+	return sb.String()
+}
+
+func generateSecretValue() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
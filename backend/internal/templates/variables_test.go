@@ -0,0 +1,113 @@
+package templates
+
+import (
+	"testing"
+
+	"apex-build/internal/secrets"
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func variablesTemplate() *Template {
+	return &Template{
+		ID:       "vars",
+		Name:     "Vars",
+		Language: "javascript",
+		Files: []TemplateFile{
+			{Path: "server.js", Content: "const mode = '{{apex.NODE_ENV}}'; const name = '{{ apex.APP_NAME }}'; const key = '{{apex.API_KEY}}';"},
+		},
+		EnvVars: []EnvVar{
+			{Name: "APP_NAME", Description: "App name", Required: true},
+			{Name: "NODE_ENV", Description: "Environment", Type: VariableEnum, Options: []string{"development", "production"}, Default: "development"},
+			{Name: "API_KEY", Description: "Third-party API key", Type: VariableSecret, Required: true},
+			{Name: "JWT_SECRET", Description: "JWT signing secret", Type: VariableSecret, Required: true, Generate: true},
+		},
+	}
+}
+
+func TestPromptSchemaAndValidation(t *testing.T) {
+	template := variablesTemplate()
+	schema := template.PromptSchema()
+	require.Len(t, schema, 4)
+	require.Equal(t, "text", schema[0].Input)
+	require.False(t, schema[0].Optional)
+	require.Equal(t, "select", schema[1].Input)
+	require.True(t, schema[1].Optional)
+	require.Equal(t, "password", schema[2].Input)
+	require.True(t, schema[2].Optional)
+	require.True(t, schema[3].Generated)
+
+	_, err := template.ResolveVariables(map[string]string{"NODE_ENV": "staging", "EXTRA": "x", "API_KEY": "a\nb"})
+	var errs VariableErrors
+	require.ErrorAs(t, err, &errs)
+	require.Equal(t, VariableErrors{
+		"APP_NAME": "is required",
+		"NODE_ENV": "must be one of development, production",
+		"EXTRA":    "is not a variable of this template",
+		"API_KEY":  "must be a single line",
+	}, errs)
+}
+
+func TestCreateProjectWithVariables(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Project{}, &models.File{}, &secrets.Secret{}))
+	manager, err := secrets.NewSecretsManager("Rdr7pQ2zLm4xVn8cHs5wTy9kBd3fJu6mZa1rNe0q")
+	require.NoError(t, err)
+
+	project, _, resolved, err := CreateProjectWithVariables(db, manager, 7, variablesTemplate(), "shop", "", map[string]string{"APP_NAME": "Shop"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"APP_NAME": "Shop", "NODE_ENV": "development"}, resolved.Values)
+
+	var files []models.File
+	require.NoError(t, db.Where("project_id = ?", project.ID).Find(&files).Error)
+	byPath := map[string]string{}
+	for _, f := range files {
+		byPath[f.Path] = f.Content
+	}
+	require.Equal(t, "const mode = 'development'; const name = 'Shop'; const key = '{{apex.API_KEY}}';", byPath["server.js"])
+	require.Contains(t, byPath[".env"], "APP_NAME=Shop\n")
+	require.Contains(t, byPath[".env"], "# API_KEY is a project secret")
+	require.Contains(t, byPath, ".env.example")
+
+	var stored []secrets.Secret
+	require.NoError(t, db.Where("project_id = ?", project.ID).Order("name").Find(&stored).Error)
+	require.Len(t, stored, 2)
+	require.Equal(t, "API_KEY", stored[0].Name)
+	require.Equal(t, secrets.SecretTypeEnvironment, stored[0].Type)
+	require.Contains(t, stored[0].Description, "placeholder")
+	placeholder, err := manager.Decrypt(7, stored[0].EncryptedValue, stored[0].Salt)
+	require.NoError(t, err)
+	require.Empty(t, placeholder)
+	generated, err := manager.Decrypt(7, stored[1].EncryptedValue, stored[1].Salt)
+	require.NoError(t, err)
+	require.Len(t, generated, 64)
+
+	// Without variables (sample projects) required fields are not enforced
+	// and nothing is written to secrets or .env
+	plain, count, err := CreateProject(db, 7, variablesTemplate(), "sample", "")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	var secretCount int64
+	require.NoError(t, db.Model(&secrets.Secret{}).Where("project_id = ?", plain.ID).Count(&secretCount).Error)
+	require.Zero(t, secretCount)
+}
+
+func TestBuiltInTemplateVariables(t *testing.T) {
+	for _, template := range GetAllTemplates() {
+		for _, v := range template.EnvVars {
+			switch v.variableType() {
+			case VariableSecret:
+				require.Empty(t, v.Default, "%s/%s: secrets have no default", template.ID, v.Name)
+			case VariableEnum:
+				require.NotEmpty(t, v.Options, "%s/%s", template.ID, v.Name)
+				if v.Default != "" {
+					require.Contains(t, v.Options, v.Default, "%s/%s", template.ID, v.Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Package trash exposes soft-deleted projects and files as a per-user trash.
+// DeleteProject/DeleteFile only set deleted_at; items stay restorable for
+// RetentionPeriod and are then purged for good by the background job.
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"apex-build/internal/privacy"
+	"apex-build/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// RetentionPeriod is how long trashed items are kept before purging.
+const RetentionPeriod = 30 * 24 * time.Hour
+
+// DefaultPurgeInterval is how often the purge job runs.
+const DefaultPurgeInterval = time.Hour
+
+// Item types returned by List.
+const (
+	ItemProject = "project"
+	ItemFile    = "file"
+)
+
+var (
+	// ErrNotFound is returned when the item is not in the user's trash.
+	ErrNotFound = errors.New("item not found in trash")
+	// ErrPathConflict is returned when restoring a file whose path is now
+	// occupied by a live file.
+	ErrPathConflict = errors.New("a file already exists at this path")
+	// ErrProjectTrashed is returned when restoring a file whose project is
+	// itself in the trash.
+	ErrProjectTrashed = errors.New("restore the project first")
+)
+
+// Item is one entry in the trash listing.
+type Item struct {
+	Type        string    `json:"type"`
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Path        string    `json:"path,omitempty"`
+	ProjectID   uint      `json:"project_id"`
+	ProjectName string    `json:"project_name,omitempty"`
+	SizeBytes   int64     `json:"size_bytes"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+// UsageRefresher drops cached quota usage after trash changes. Implemented by
+// *usage.Tracker.
+type UsageRefresher interface {
+	ForceRefresh(ctx context.Context, userID uint) error
+}
+
+// Service lists, restores, and purges trashed projects and files.
+type Service struct {
+	db    *gorm.DB
+	usage UsageRefresher
+}
+
+// NewService creates a new trash Service.
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// SetUsageRefresher wires the usage tracker so restores and purges are
+// reflected in quota checks immediately.
+func (s *Service) SetUsageRefresher(u UsageRefresher) {
+	s.usage = u
+}
+
+// List returns everything in the user's trash, newest first. Files inside a
+// trashed project are represented by the project itself.
+func (s *Service) List(ctx context.Context, userID uint) ([]Item, error) {
+	var projects []struct {
+		ID        uint
+		Name      string
+		DeletedAt time.Time
+		SizeBytes int64
+	}
+	if err := s.db.WithContext(ctx).Raw(`
+		SELECT p.id, p.name, p.deleted_at, COALESCE(SUM(f.size), 0) AS size_bytes
+		FROM projects p
+		LEFT JOIN files f ON f.project_id = p.id
+		WHERE p.owner_id = ? AND p.deleted_at IS NOT NULL
+		GROUP BY p.id, p.name, p.deleted_at
+	`, userID).Scan(&projects).Error; err != nil {
+		return nil, fmt.Errorf("trash: list projects failed: %w", err)
+	}
+
+	var files []struct {
+		ID          uint
+		Name        string
+		Path        string
+		ProjectID   uint
+		ProjectName string
+		Size        int64
+		DeletedAt   time.Time
+	}
+	if err := s.db.WithContext(ctx).Raw(`
+		SELECT f.id, f.name, f.path, f.project_id, p.name AS project_name, f.size, f.deleted_at
+		FROM files f
+		JOIN projects p ON f.project_id = p.id
+		WHERE p.owner_id = ? AND p.deleted_at IS NULL AND f.deleted_at IS NOT NULL
+	`, userID).Scan(&files).Error; err != nil {
+		return nil, fmt.Errorf("trash: list files failed: %w", err)
+	}
+
+	items := make([]Item, 0, len(projects)+len(files))
+	for _, p := range projects {
+		items = append(items, Item{
+			Type:      ItemProject,
+			ID:        p.ID,
+			Name:      p.Name,
+			ProjectID: p.ID,
+			SizeBytes: p.SizeBytes,
+			DeletedAt: p.DeletedAt,
+			PurgeAt:   p.DeletedAt.Add(RetentionPeriod),
+		})
+	}
+	for _, f := range files {
+		items = append(items, Item{
+			Type:        ItemFile,
+			ID:          f.ID,
+			Name:        f.Name,
+			Path:        f.Path,
+			ProjectID:   f.ProjectID,
+			ProjectName: f.ProjectName,
+			SizeBytes:   f.Size,
+			DeletedAt:   f.DeletedAt,
+			PurgeAt:     f.DeletedAt.Add(RetentionPeriod),
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// RestoreProject moves a trashed project back to the user's project list.
+// Project quota is enforced by the route's quota middleware.
+func (s *Service) RestoreProject(ctx context.Context, userID, projectID uint) (*models.Project, error) {
+	project, err := s.trashedProject(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Unscoped().Model(project).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("trash: restore project failed: %w", err)
+	}
+	project.DeletedAt = gorm.DeletedAt{}
+	s.refresh(ctx, userID)
+	return project, nil
+}
+
+// RestoreFile moves a trashed file back into its project.
+func (s *Service) RestoreFile(ctx context.Context, userID, fileID uint) (*models.File, error) {
+	file, err := s.trashedFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.Project.DeletedAt.Valid {
+		return nil, ErrProjectTrashed
+	}
+
+	var conflicts int64
+	if err := s.db.WithContext(ctx).Model(&models.File{}).
+		Where("project_id = ? AND path = ?", file.ProjectID, file.Path).
+		Count(&conflicts).Error; err != nil {
+		return nil, fmt.Errorf("trash: path conflict check failed: %w", err)
+	}
+	if conflicts > 0 {
+		return nil, ErrPathConflict
+	}
+
+	if err := s.db.WithContext(ctx).Unscoped().Model(file).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("trash: restore file failed: %w", err)
+	}
+	file.DeletedAt = gorm.DeletedAt{}
+	s.refresh(ctx, userID)
+	return file, nil
+}
+
+// PurgeProject permanently deletes a trashed project, its files and the rest
+// of its data.
+func (s *Service) PurgeProject(ctx context.Context, userID, projectID uint) error {
+	project, err := s.trashedProject(ctx, userID, projectID)
+	if err != nil {
+		return err
+	}
+	if err := s.purgeProject(ctx, project.ID); err != nil {
+		return err
+	}
+	s.refresh(ctx, userID)
+	return nil
+}
+
+// PurgeFile permanently deletes a trashed file.
+func (s *Service) PurgeFile(ctx context.Context, userID, fileID uint) error {
+	file, err := s.trashedFile(ctx, userID, fileID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Unscoped().Delete(&models.File{}, file.ID).Error; err != nil {
+		return fmt.Errorf("trash: purge file failed: %w", err)
+	}
+	s.refresh(ctx, userID)
+	return nil
+}
+
+// Empty permanently deletes everything in the user's trash.
+func (s *Service) Empty(ctx context.Context, userID uint) (int64, error) {
+	var projectIDs []uint
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Project{}).
+		Where("owner_id = ? AND deleted_at IS NOT NULL", userID).
+		Pluck("id", &projectIDs).Error; err != nil {
+		return 0, fmt.Errorf("trash: list trashed projects failed: %w", err)
+	}
+	for _, id := range projectIDs {
+		if err := s.purgeProject(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	res := s.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND project_id IN (?)",
+			s.db.Unscoped().Model(&models.Project{}).Select("id").Where("owner_id = ?", userID)).
+		Delete(&models.File{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("trash: purge files failed: %w", res.Error)
+	}
+	s.refresh(ctx, userID)
+	return int64(len(projectIDs)) + res.RowsAffected, nil
+}
+
+// PurgeExpired permanently deletes items trashed longer than RetentionPeriod.
+func (s *Service) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	cutoff := now.Add(-RetentionPeriod)
+
+	var projectIDs []uint
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Project{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &projectIDs).Error; err != nil {
+		return 0, fmt.Errorf("trash: list expired projects failed: %w", err)
+	}
+	var purged int64
+	for _, id := range projectIDs {
+		// One project still referenced elsewhere must not block the rest.
+		if err := s.purgeProject(ctx, id); err != nil {
+			log.Printf("trash: purge project %d failed: %v", id, err)
+			continue
+		}
+		purged++
+	}
+
+	res := s.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Delete(&models.File{})
+	if res.Error != nil {
+		return purged, fmt.Errorf("trash: purge expired files failed: %w", res.Error)
+	}
+	return purged + res.RowsAffected, nil
+}
+
+// Start runs PurgeExpired on a ticker until ctx is cancelled.
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := s.PurgeExpired(ctx, time.Now().UTC())
+				if err != nil {
+					log.Printf("trash: purge failed: %v", err)
+				} else if purged > 0 {
+					log.Printf("trash: purged %d expired items", purged)
+				}
+			}
+		}
+	}()
+}
+
+// purgeProject removes the project with its files, executions, secrets and
+// every other row that belongs to it in one transaction, using the same
+// cascade as account deletion.
+func (s *Service) purgeProject(ctx context.Context, projectID uint) error {
+	if _, err := privacy.PurgeProjects(s.db.WithContext(ctx), []uint{projectID}); err != nil {
+		return fmt.Errorf("trash: purge project failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) trashedProject(ctx context.Context, userID, projectID uint) (*models.Project, error) {
+	var project models.Project
+	err := s.db.WithContext(ctx).Unscoped().
+		Where("id = ? AND owner_id = ? AND deleted_at IS NOT NULL", projectID, userID).
+		First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trash: load project failed: %w", err)
+	}
+	return &project, nil
+}
+
+func (s *Service) trashedFile(ctx context.Context, userID, fileID uint) (*models.File, error) {
+	var file models.File
+	err := s.db.WithContext(ctx).Unscoped().
+		Preload("Project", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("id = ? AND deleted_at IS NOT NULL", fileID).
+		First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trash: load file failed: %w", err)
+	}
+	if file.Project.ID == 0 || file.Project.OwnerID != userID {
+		return nil, ErrNotFound
+	}
+	return &file, nil
+}
+
+func (s *Service) refresh(ctx context.Context, userID uint) {
+	if s.usage != nil {
+		_ = s.usage.ForceRefresh(ctx, userID)
+	}
+}
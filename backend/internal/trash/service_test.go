@@ -0,0 +1,220 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeUsageRefresher struct{ refreshed []uint }
+
+func (f *fakeUsageRefresher) ForceRefresh(ctx context.Context, userID uint) error {
+	f.refreshed = append(f.refreshed, userID)
+	return nil
+}
+
+func setupTrashTest(t *testing.T) (*Service, *gorm.DB, uint, *fakeUsageRefresher) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	name := strings.ToLower(strings.ReplaceAll(t.Name(), "/", "_"))
+	user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	refresher := &fakeUsageRefresher{}
+	svc := NewService(db)
+	svc.SetUsageRefresher(refresher)
+	return svc, db, user.ID, refresher
+}
+
+func createProjectWithFile(t *testing.T, db *gorm.DB, ownerID uint, name, path string, size int64) (models.Project, models.File) {
+	t.Helper()
+	project := models.Project{Name: name, Language: "typescript", OwnerID: ownerID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	file := models.File{ProjectID: project.ID, Path: path, Name: path, Type: "file", Content: "x", Size: size}
+	if err := db.Create(&file).Error; err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	return project, file
+}
+
+func TestListAndRestoreTrashedProjectAndFile(t *testing.T) {
+	svc, db, userID, refresher := setupTrashTest(t)
+	ctx := context.Background()
+
+	trashedProject, _ := createProjectWithFile(t, db, userID, "old-app", "index.ts", 100)
+	liveProject, liveFile := createProjectWithFile(t, db, userID, "live-app", "main.ts", 40)
+	if err := db.Delete(&trashedProject).Error; err != nil {
+		t.Fatalf("soft delete project: %v", err)
+	}
+	if err := db.Delete(&liveFile).Error; err != nil {
+		t.Fatalf("soft delete file: %v", err)
+	}
+
+	items, err := svc.List(ctx, userID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected project + file in trash, got %+v", items)
+	}
+	for _, item := range items {
+		if item.PurgeAt.Sub(item.DeletedAt) != RetentionPeriod {
+			t.Fatalf("unexpected purge_at for %+v", item)
+		}
+		if item.Type == ItemProject && (item.ID != trashedProject.ID || item.SizeBytes != 100) {
+			t.Fatalf("unexpected project item %+v", item)
+		}
+		if item.Type == ItemFile && (item.ID != liveFile.ID || item.ProjectName != liveProject.Name) {
+			t.Fatalf("unexpected file item %+v", item)
+		}
+	}
+
+	if _, err := svc.RestoreProject(ctx, userID, trashedProject.ID); err != nil {
+		t.Fatalf("RestoreProject: %v", err)
+	}
+	if _, err := svc.RestoreFile(ctx, userID, liveFile.ID); err != nil {
+		t.Fatalf("RestoreFile: %v", err)
+	}
+	var liveProjects int64
+	db.Model(&models.Project{}).Where("owner_id = ?", userID).Count(&liveProjects)
+	if liveProjects != 2 {
+		t.Fatalf("expected both projects live after restore, got %d", liveProjects)
+	}
+	if len(refresher.refreshed) != 2 {
+		t.Fatalf("expected usage refresh after each restore, got %v", refresher.refreshed)
+	}
+
+	if _, err := svc.RestoreProject(ctx, userID+1, trashedProject.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another user, got %v", err)
+	}
+}
+
+func TestRestoreFileRejectsPathConflictAndTrashedProject(t *testing.T) {
+	svc, db, userID, _ := setupTrashTest(t)
+	ctx := context.Background()
+
+	project, file := createProjectWithFile(t, db, userID, "app", "index.ts", 10)
+	if err := db.Delete(&file).Error; err != nil {
+		t.Fatalf("soft delete file: %v", err)
+	}
+	replacement := models.File{ProjectID: project.ID, Path: "index.ts", Name: "index.ts", Type: "file"}
+	if err := db.Create(&replacement).Error; err != nil {
+		t.Fatalf("create replacement: %v", err)
+	}
+	if _, err := svc.RestoreFile(ctx, userID, file.ID); !errors.Is(err, ErrPathConflict) {
+		t.Fatalf("expected ErrPathConflict, got %v", err)
+	}
+
+	if err := db.Delete(&project).Error; err != nil {
+		t.Fatalf("soft delete project: %v", err)
+	}
+	if _, err := svc.RestoreFile(ctx, userID, file.ID); !errors.Is(err, ErrProjectTrashed) {
+		t.Fatalf("expected ErrProjectTrashed, got %v", err)
+	}
+}
+
+func TestPurgeExpiredRemovesOnlyItemsPastRetention(t *testing.T) {
+	svc, db, userID, _ := setupTrashTest(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	expired, _ := createProjectWithFile(t, db, userID, "expired", "a.ts", 10)
+	recent, _ := createProjectWithFile(t, db, userID, "recent", "b.ts", 10)
+	_, oldFile := createProjectWithFile(t, db, userID, "live", "c.ts", 10)
+
+	old := now.Add(-RetentionPeriod - time.Hour)
+	db.Unscoped().Model(&models.Project{}).Where("id = ?", expired.ID).Update("deleted_at", old)
+	db.Unscoped().Model(&models.Project{}).Where("id = ?", recent.ID).Update("deleted_at", now.Add(-time.Hour))
+	db.Unscoped().Model(&models.File{}).Where("id = ?", oldFile.ID).Update("deleted_at", old)
+
+	purged, err := svc.PurgeExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("expected expired project and file purged, got %d", purged)
+	}
+
+	var remaining int64
+	db.Unscoped().Model(&models.Project{}).Where("id = ?", expired.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatal("expired project should be hard deleted")
+	}
+	db.Unscoped().Model(&models.File{}).Where("project_id = ?", expired.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatal("expired project's files should be hard deleted")
+	}
+	db.Unscoped().Model(&models.Project{}).Where("id = ?", recent.ID).Count(&remaining)
+	if remaining != 1 {
+		t.Fatal("recently trashed project must be kept")
+	}
+
+	items, err := svc.List(ctx, userID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != recent.ID {
+		t.Fatalf("expected only the recent project in trash, got %+v", items)
+	}
+}
+
+func TestPurgeProjectRemovesDependentRows(t *testing.T) {
+	svc, db, userID, _ := setupTrashTest(t)
+	ctx := context.Background()
+	if err := db.AutoMigrate(&models.Execution{}, &models.CollabRoom{}, &models.AIRequest{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	trashed, _ := createProjectWithFile(t, db, userID, "trashed", "a.ts", 10)
+	live, _ := createProjectWithFile(t, db, userID, "live", "b.ts", 10)
+	for _, project := range []models.Project{trashed, live} {
+		projectID := project.ID
+		rows := []interface{}{
+			&models.Execution{ExecutionID: project.Name, ProjectID: &projectID, UserID: userID, Command: "npm test", Language: "typescript"},
+			&models.CollabRoom{RoomID: project.Name, ProjectID: projectID},
+			&models.AIRequest{RequestID: project.Name, UserID: userID, ProjectID: &projectID, Provider: "claude", Capability: "code_generation"},
+		}
+		for _, row := range rows {
+			if err := db.Omit("User", "Project").Create(row).Error; err != nil {
+				t.Fatalf("seed %T: %v", row, err)
+			}
+		}
+	}
+	if err := db.Delete(&models.Project{}, trashed.ID).Error; err != nil {
+		t.Fatalf("trash project: %v", err)
+	}
+
+	if err := svc.PurgeProject(ctx, userID, trashed.ID); err != nil {
+		t.Fatalf("PurgeProject: %v", err)
+	}
+
+	for _, model := range []interface{}{&models.File{}, &models.Execution{}, &models.CollabRoom{}, &models.AIRequest{}} {
+		var purged, kept int64
+		db.Unscoped().Model(model).Where("project_id = ?", trashed.ID).Count(&purged)
+		db.Unscoped().Model(model).Where("project_id = ?", live.ID).Count(&kept)
+		if purged != 0 || kept != 1 {
+			t.Fatalf("%T: %d rows left for the purged project, %d for the live one", model, purged, kept)
+		}
+	}
+	var remaining int64
+	db.Unscoped().Model(&models.Project{}).Where("id = ?", trashed.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatal("purged project should be hard deleted")
+	}
+}
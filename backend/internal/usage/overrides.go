@@ -0,0 +1,503 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-build/internal/enterprise"
+
+	"gorm.io/gorm"
+)
+
+// Quota override modes.
+const (
+	// OverrideBoost adds Amount to the plan limit until the override expires.
+	OverrideBoost = "boost"
+	// OverrideLimit replaces the plan limit with Amount (-1 for unlimited).
+	OverrideLimit = "limit"
+)
+
+// Override bounds.
+const (
+	MaxOverrideDuration     = 366 * 24 * time.Hour
+	MaxOverrideReasonLength = 500
+	// DefaultOverrideExpiryInterval is how often lapsed overrides are closed
+	// out and audited.
+	DefaultOverrideExpiryInterval = time.Minute
+)
+
+var (
+	ErrInvalidOverride         = errors.New("invalid quota override")
+	ErrOverrideNotFound        = errors.New("quota override not found")
+	ErrOverrideSubjectNotFound = errors.New("quota override subject not found")
+	ErrOverrideClosed          = errors.New("quota override already expired or revoked")
+)
+
+// QuotaOverride raises or replaces a user's or organization's plan limit for
+// one usage type, usually for a limited time. Organization overrides apply
+// to every active member. Overrides are never deleted so the history stays
+// auditable; they stop applying once expired or revoked.
+type QuotaOverride struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	SubjectType string     `json:"subject_type" gorm:"size:20;not null;index:idx_quota_override_subject,priority:1"`
+	SubjectID   uint       `json:"subject_id" gorm:"not null;index:idx_quota_override_subject,priority:2"`
+	Type        UsageType  `json:"usage_type" gorm:"size:50;not null"`
+	Mode        string     `json:"mode" gorm:"size:10;not null"`
+	Amount      int64      `json:"amount"`
+	Reason      string     `json:"reason" gorm:"size:500"`
+	StartsAt    time.Time  `json:"starts_at" gorm:"not null"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	CreatedBy   uint       `json:"created_by"`
+	ExpiredAt   *time.Time `json:"expired_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy   *uint      `json:"revoked_by,omitempty"`
+	RevokeNote  string     `json:"revoke_note,omitempty" gorm:"size:500"`
+}
+
+// TableName keeps the table name explicit.
+func (QuotaOverride) TableName() string { return "quota_overrides" }
+
+// Status is active, scheduled, expired or revoked at now.
+func (o *QuotaOverride) Status(now time.Time) string {
+	switch {
+	case o.RevokedAt != nil:
+		return "revoked"
+	case o.ExpiredAt != nil || (o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)):
+		return "expired"
+	case now.Before(o.StartsAt):
+		return "scheduled"
+	default:
+		return "active"
+	}
+}
+
+// ActiveOverride is an override applied to a user's current limits.
+type ActiveOverride struct {
+	ID        uint       `json:"id"`
+	Source    string     `json:"source"` // user or organization
+	UsageType UsageType  `json:"usage_type"`
+	Mode      string     `json:"mode"`
+	Amount    int64      `json:"amount"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// OverrideInput is an admin's request to create an override.
+type OverrideInput struct {
+	SubjectType string
+	SubjectID   uint
+	Type        UsageType
+	Mode        string
+	Amount      int64
+	Reason      string
+	StartsAt    *time.Time
+	ExpiresAt   *time.Time
+}
+
+// OverrideFilter narrows ListOverrides. Status is active, scheduled,
+// expired, revoked or empty for all.
+type OverrideFilter struct {
+	SubjectType string
+	SubjectID   uint
+	Status      string
+	Limit       int
+}
+
+// OverrideAuditLogger records override changes. Implemented by
+// *enterprise.AuditService; wired via SetAuditLogger.
+type OverrideAuditLogger interface {
+	LogEvent(log *enterprise.AuditLog)
+}
+
+// SetAuditLogger makes override changes land in the audit log.
+func (t *Tracker) SetAuditLogger(audit OverrideAuditLogger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.audit = audit
+}
+
+func validOverrideUsageType(usageType UsageType) bool {
+	switch usageType {
+	case UsageProjects, UsageStorageBytes, UsageAIRequests, UsageExecutionMinutes:
+		return true
+	}
+	return false
+}
+
+func invalidOverride(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOverride, fmt.Sprintf(format, args...))
+}
+
+// CreateOverride validates and stores an override, then refreshes the
+// cached limits of everyone it applies to. Boosts must expire; limit
+// overrides may be open-ended.
+func (t *Tracker) CreateOverride(ctx context.Context, actorID uint, in OverrideInput) (*QuotaOverride, error) {
+	now := time.Now().UTC()
+	in.Reason = strings.TrimSpace(in.Reason)
+	switch {
+	case in.SubjectType != SubjectUser && in.SubjectType != SubjectOrganization:
+		return nil, invalidOverride("subject_type must be user or organization")
+	case in.SubjectID == 0:
+		return nil, invalidOverride("subject_id is required")
+	case !validOverrideUsageType(in.Type):
+		return nil, invalidOverride("usage_type must be projects, storage_bytes, ai_requests or execution_minutes")
+	case in.Mode != OverrideBoost && in.Mode != OverrideLimit:
+		return nil, invalidOverride("mode must be boost or limit")
+	case in.Mode == OverrideBoost && in.Amount <= 0:
+		return nil, invalidOverride("a boost amount must be positive")
+	case in.Mode == OverrideLimit && in.Amount < -1:
+		return nil, invalidOverride("a limit must be -1 (unlimited) or more")
+	case in.Reason == "":
+		return nil, invalidOverride("reason is required")
+	case len(in.Reason) > MaxOverrideReasonLength:
+		return nil, invalidOverride("reason must be at most %d characters", MaxOverrideReasonLength)
+	}
+
+	startsAt := now
+	if in.StartsAt != nil && in.StartsAt.After(now) {
+		startsAt = in.StartsAt.UTC()
+	}
+	var expiresAt *time.Time
+	if in.ExpiresAt != nil {
+		at := in.ExpiresAt.UTC()
+		expiresAt = &at
+	}
+	switch {
+	case expiresAt == nil && in.Mode == OverrideBoost:
+		return nil, invalidOverride("a boost needs expires_at")
+	case expiresAt != nil && !expiresAt.After(startsAt):
+		return nil, invalidOverride("expires_at must be after the override starts")
+	case expiresAt != nil && expiresAt.Sub(startsAt) > MaxOverrideDuration:
+		return nil, invalidOverride("an override can last at most %d days", int(MaxOverrideDuration.Hours()/24))
+	}
+
+	if err := t.overrideSubjectExists(ctx, in.SubjectType, in.SubjectID); err != nil {
+		return nil, err
+	}
+
+	override := &QuotaOverride{
+		SubjectType: in.SubjectType,
+		SubjectID:   in.SubjectID,
+		Type:        in.Type,
+		Mode:        in.Mode,
+		Amount:      in.Amount,
+		Reason:      in.Reason,
+		StartsAt:    startsAt,
+		ExpiresAt:   expiresAt,
+		CreatedBy:   actorID,
+	}
+	if err := t.db.WithContext(ctx).Create(override).Error; err != nil {
+		return nil, fmt.Errorf("failed to create quota override: %w", err)
+	}
+	t.invalidateOverrideSubject(ctx, override)
+	t.auditOverride(override, &actorID, "quota_override_created", "Quota override created", map[string]interface{}{
+		"usage_type": string(override.Type),
+		"mode":       override.Mode,
+		"amount":     override.Amount,
+		"starts_at":  override.StartsAt,
+		"expires_at": override.ExpiresAt,
+		"reason":     override.Reason,
+	})
+	return override, nil
+}
+
+// ListOverrides returns overrides newest first.
+func (t *Tracker) ListOverrides(ctx context.Context, filter OverrideFilter) ([]QuotaOverride, error) {
+	now := time.Now().UTC()
+	query := t.db.WithContext(ctx).Model(&QuotaOverride{})
+	if filter.SubjectType != "" {
+		query = query.Where("subject_type = ?", filter.SubjectType)
+	}
+	if filter.SubjectID != 0 {
+		query = query.Where("subject_id = ?", filter.SubjectID)
+	}
+	switch filter.Status {
+	case "":
+	case "active":
+		query = activeOverrides(query, now)
+	case "scheduled":
+		query = query.Where("revoked_at IS NULL AND starts_at > ?", now)
+	case "expired":
+		query = query.Where("revoked_at IS NULL AND (expired_at IS NOT NULL OR expires_at <= ?)", now)
+	case "revoked":
+		query = query.Where("revoked_at IS NOT NULL")
+	default:
+		return nil, invalidOverride("status must be active, scheduled, expired or revoked")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 200 {
+		limit = 100
+	}
+	var overrides []QuotaOverride
+	if err := query.Order("id DESC").Limit(limit).Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to list quota overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// RevokeOverride ends an override early.
+func (t *Tracker) RevokeOverride(ctx context.Context, actorID, id uint, note string) (*QuotaOverride, error) {
+	var override QuotaOverride
+	if err := t.db.WithContext(ctx).First(&override, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOverrideNotFound
+		}
+		return nil, fmt.Errorf("failed to load quota override: %w", err)
+	}
+	now := time.Now().UTC()
+	if status := override.Status(now); status == "expired" || status == "revoked" {
+		return nil, ErrOverrideClosed
+	}
+	note = strings.TrimSpace(note)
+	if len(note) > MaxOverrideReasonLength {
+		return nil, invalidOverride("note must be at most %d characters", MaxOverrideReasonLength)
+	}
+	res := t.db.WithContext(ctx).Model(&QuotaOverride{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_by": actorID, "revoke_note": note})
+	if res.Error != nil {
+		return nil, fmt.Errorf("failed to revoke quota override: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrOverrideClosed
+	}
+	override.RevokedAt = &now
+	override.RevokedBy = &actorID
+	override.RevokeNote = note
+	t.invalidateOverrideSubject(ctx, &override)
+	t.auditOverride(&override, &actorID, "quota_override_revoked", "Quota override revoked", map[string]interface{}{
+		"usage_type": string(override.Type),
+		"note":       note,
+	})
+	return &override, nil
+}
+
+// ExpireOverrides closes out overrides whose expiry has passed: they are
+// stamped, audited and the affected limits refreshed. Enforcement already
+// ignores lapsed overrides, so this only makes the reversion visible.
+func (t *Tracker) ExpireOverrides(ctx context.Context, now time.Time) (int, error) {
+	var lapsed []QuotaOverride
+	if err := t.db.WithContext(ctx).
+		Where("expired_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now).
+		Find(&lapsed).Error; err != nil {
+		return 0, fmt.Errorf("failed to load lapsed quota overrides: %w", err)
+	}
+	expired := 0
+	for i := range lapsed {
+		override := &lapsed[i]
+		res := t.db.WithContext(ctx).Model(&QuotaOverride{}).
+			Where("id = ? AND expired_at IS NULL", override.ID).
+			Update("expired_at", now)
+		if res.Error != nil {
+			return expired, fmt.Errorf("failed to expire quota override %d: %w", override.ID, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		override.ExpiredAt = &now
+		expired++
+		t.invalidateOverrideSubject(ctx, override)
+		t.auditOverride(override, nil, "quota_override_expired", "Quota override expired and the plan limit was restored", map[string]interface{}{
+			"usage_type": string(override.Type),
+			"expires_at": override.ExpiresAt,
+		})
+	}
+	return expired, nil
+}
+
+// StartOverrideExpiry expires overrides immediately and then on every
+// interval until ctx is cancelled.
+func (t *Tracker) StartOverrideExpiry(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultOverrideExpiryInterval
+	}
+	go func() {
+		run := func() {
+			expired, err := t.ExpireOverrides(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("usage: quota override expiry failed: %v", err)
+				return
+			}
+			if expired > 0 {
+				log.Printf("usage: expired %d quota override(s)", expired)
+			}
+		}
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+}
+
+func activeOverrides(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("revoked_at IS NULL AND expired_at IS NULL AND starts_at <= ? AND (expires_at IS NULL OR expires_at > ?)", now, now)
+}
+
+// activeOverridesForUser loads the overrides that apply to a user now: their
+// own and those of organizations they are an active member of.
+func (t *Tracker) activeOverridesForUser(ctx context.Context, userID uint, now time.Time) ([]QuotaOverride, error) {
+	db := t.db.WithContext(ctx)
+	query := activeOverrides(db.Model(&QuotaOverride{}), now)
+	if db.Migrator().HasTable("organization_members") {
+		orgIDs := db.Table("organization_members").Select("organization_id").
+			Where("user_id = ? AND status = ? AND deleted_at IS NULL", userID, "active")
+		query = query.Where("(subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id IN (?))",
+			SubjectUser, userID, SubjectOrganization, orgIDs)
+	} else {
+		query = query.Where("subject_type = ? AND subject_id = ?", SubjectUser, userID)
+	}
+	var overrides []QuotaOverride
+	if err := query.Order("id ASC").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// applyActiveOverrides replaces the plan limits in usage with the effective
+// limits once the user's active overrides are taken into account.
+func (t *Tracker) applyActiveOverrides(ctx context.Context, usage *CurrentUsage) error {
+	if !t.db.WithContext(ctx).Migrator().HasTable(&QuotaOverride{}) {
+		return nil
+	}
+	overrides, err := t.activeOverridesForUser(ctx, usage.UserID, time.Now().UTC())
+	if err != nil || len(overrides) == 0 {
+		return err
+	}
+	usage.ProjectsLimit = int(applyOverrides(int64(usage.ProjectsLimit), UsageProjects, overrides))
+	usage.StorageLimit = applyOverrides(usage.StorageLimit, UsageStorageBytes, overrides)
+	usage.AIRequestsLimit = int(applyOverrides(int64(usage.AIRequestsLimit), UsageAIRequests, overrides))
+	usage.ExecutionLimit = int(applyOverrides(int64(usage.ExecutionLimit), UsageExecutionMinutes, overrides))
+	for _, o := range overrides {
+		usage.Overrides = append(usage.Overrides, ActiveOverride{
+			ID:        o.ID,
+			Source:    o.SubjectType,
+			UsageType: o.Type,
+			Mode:      o.Mode,
+			Amount:    o.Amount,
+			ExpiresAt: o.ExpiresAt,
+		})
+	}
+	return nil
+}
+
+// applyOverrides resolves the effective limit for one usage type. Overrides
+// are consulted before the plan default: a user's own limit override wins
+// over an organization's, the most generous organization limit wins among
+// organizations, and boosts then add to whatever limit results. Unlimited
+// stays unlimited.
+func applyOverrides(planLimit int64, usageType UsageType, overrides []QuotaOverride) int64 {
+	limit := planLimit
+	var userLimit, orgLimit *int64
+	var boost int64
+	for i := range overrides {
+		o := &overrides[i]
+		if o.Type != usageType {
+			continue
+		}
+		switch o.Mode {
+		case OverrideBoost:
+			boost += o.Amount
+		case OverrideLimit:
+			amount := o.Amount
+			if o.SubjectType == SubjectUser {
+				// Overrides are ordered oldest first, so the newest wins.
+				userLimit = &amount
+			} else if orgLimit == nil || *orgLimit != -1 && (amount == -1 || amount > *orgLimit) {
+				orgLimit = &amount
+			}
+		}
+	}
+	switch {
+	case userLimit != nil:
+		limit = *userLimit
+	case orgLimit != nil:
+		limit = *orgLimit
+	}
+	if limit == -1 {
+		return -1
+	}
+	return limit + boost
+}
+
+func (t *Tracker) overrideSubjectExists(ctx context.Context, subjectType string, subjectID uint) error {
+	table := "users"
+	if subjectType == SubjectOrganization {
+		table = "organizations"
+	}
+	db := t.db.WithContext(ctx)
+	if !db.Migrator().HasTable(table) {
+		return ErrOverrideSubjectNotFound
+	}
+	var count int64
+	if err := db.Table(table).Where("id = ? AND deleted_at IS NULL", subjectID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to look up %s: %w", subjectType, err)
+	}
+	if count == 0 {
+		return ErrOverrideSubjectNotFound
+	}
+	return nil
+}
+
+// invalidateOverrideSubject drops cached usage for everyone the override
+// applies to so the new limits take effect on their next request.
+func (t *Tracker) invalidateOverrideSubject(ctx context.Context, o *QuotaOverride) {
+	if o.SubjectType == SubjectUser {
+		t.invalidateCache(o.SubjectID)
+		return
+	}
+	db := t.db.WithContext(ctx)
+	if !db.Migrator().HasTable("organization_members") {
+		return
+	}
+	var userIDs []uint
+	if err := db.Table("organization_members").
+		Where("organization_id = ? AND deleted_at IS NULL", o.SubjectID).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("usage: could not refresh limits for organization %d members: %v", o.SubjectID, err)
+		return
+	}
+	for _, userID := range userIDs {
+		t.invalidateCache(userID)
+	}
+}
+
+func (t *Tracker) auditOverride(o *QuotaOverride, actorID *uint, action, description string, values map[string]interface{}) {
+	t.mu.RLock()
+	audit := t.audit
+	t.mu.RUnlock()
+	if audit == nil {
+		return
+	}
+	entry := &enterprise.AuditLog{
+		UserID:       actorID,
+		Action:       action,
+		ResourceType: "quota_override",
+		ResourceID:   strconv.FormatUint(uint64(o.ID), 10),
+		ResourceName: fmt.Sprintf("%s:%d", o.SubjectType, o.SubjectID),
+		Category:     "system",
+		Description:  description,
+		NewValue:     values,
+		Metadata:     map[string]interface{}{"via": "admin_api", "subject_type": o.SubjectType, "subject_id": o.SubjectID},
+	}
+	if o.SubjectType == SubjectOrganization {
+		orgID := o.SubjectID
+		entry.OrganizationID = &orgID
+	}
+	if actorID == nil {
+		entry.Metadata["via"] = "expiry"
+	}
+	audit.LogEvent(entry)
+}
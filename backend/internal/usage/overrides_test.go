@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"apex-build/internal/enterprise"
+	"apex-build/pkg/models"
+)
+
+type recordingAudit struct{ actions []string }
+
+func (r *recordingAudit) LogEvent(log *enterprise.AuditLog) {
+	r.actions = append(r.actions, log.Action)
+}
+
+func TestQuotaOverrideBoostAppliesUntilExpiry(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	audit := &recordingAudit{}
+	tracker.SetAuditLogger(audit)
+	ctx := context.Background()
+	user := models.User{Username: "hacker", Email: "hacker@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	planLimit := int64(GetPlanLimits(PlanFree).ExecutionMinutes)
+
+	expires := time.Now().UTC().Add(48 * time.Hour)
+	override, err := tracker.CreateOverride(ctx, 99, OverrideInput{
+		SubjectType: SubjectUser,
+		SubjectID:   user.ID,
+		Type:        UsageExecutionMinutes,
+		Mode:        OverrideBoost,
+		Amount:      30,
+		Reason:      "weekend hackathon",
+		ExpiresAt:   &expires,
+	})
+	if err != nil {
+		t.Fatalf("create override: %v", err)
+	}
+	_, _, limit, err := tracker.CheckQuota(ctx, user.ID, PlanFree, UsageExecutionMinutes, 1)
+	if err != nil || limit != planLimit+30 {
+		t.Fatalf("boosted limit = %d (%v), want %d", limit, err, planLimit+30)
+	}
+	current, err := tracker.GetCurrentUsage(ctx, user.ID, PlanFree)
+	if err != nil || len(current.Overrides) != 1 || current.Overrides[0].ID != override.ID {
+		t.Fatalf("expected the boost in current usage, got %+v (%v)", current, err)
+	}
+
+	expired, err := tracker.ExpireOverrides(ctx, expires.Add(time.Minute))
+	if err != nil || expired != 1 {
+		t.Fatalf("expired %d (%v), want 1", expired, err)
+	}
+	if again, _ := tracker.ExpireOverrides(ctx, expires.Add(time.Hour)); again != 0 {
+		t.Fatalf("expired %d overrides twice", again)
+	}
+	_, _, limit, err = tracker.CheckQuota(ctx, user.ID, PlanFree, UsageExecutionMinutes, 1)
+	if err != nil || limit != planLimit {
+		t.Fatalf("limit after expiry = %d (%v), want plan limit %d", limit, err, planLimit)
+	}
+	if _, err := tracker.RevokeOverride(ctx, 99, override.ID, ""); !errors.Is(err, ErrOverrideClosed) {
+		t.Fatalf("revoking an expired override: %v", err)
+	}
+	want := []string{"quota_override_created", "quota_override_expired"}
+	if len(audit.actions) != len(want) || audit.actions[0] != want[0] || audit.actions[1] != want[1] {
+		t.Fatalf("audit actions = %v, want %v", audit.actions, want)
+	}
+}
+
+func TestQuotaOverridesConsultedBeforePlanDefaults(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	ctx := context.Background()
+	if err := db.AutoMigrate(&enterprise.Organization{}, &enterprise.OrganizationMember{}); err != nil {
+		t.Fatalf("migrate organizations: %v", err)
+	}
+	user := models.User{Username: "member", Email: "member@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	org := enterprise.Organization{Name: "Acme", Slug: "acme"}
+	if err := db.Create(&org).Error; err != nil {
+		t.Fatalf("create org: %v", err)
+	}
+	if err := db.Create(&enterprise.OrganizationMember{OrganizationID: org.ID, UserID: user.ID, RoleID: 1, Status: "active"}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+
+	create := func(in OverrideInput) *QuotaOverride {
+		t.Helper()
+		in.Reason = "support ticket"
+		in.Type = UsageProjects
+		o, err := tracker.CreateOverride(ctx, 1, in)
+		if err != nil {
+			t.Fatalf("create override %+v: %v", in, err)
+		}
+		return o
+	}
+	limitNow := func() int64 {
+		t.Helper()
+		_, _, limit, err := tracker.CheckQuota(ctx, user.ID, PlanFree, UsageProjects, 1)
+		if err != nil {
+			t.Fatalf("check quota: %v", err)
+		}
+		return limit
+	}
+
+	create(OverrideInput{SubjectType: SubjectOrganization, SubjectID: org.ID, Mode: OverrideLimit, Amount: 50})
+	if got := limitNow(); got != 50 {
+		t.Fatalf("org limit override: limit = %d, want 50", got)
+	}
+	personal := create(OverrideInput{SubjectType: SubjectUser, SubjectID: user.ID, Mode: OverrideLimit, Amount: 20})
+	expires := time.Now().Add(time.Hour)
+	create(OverrideInput{SubjectType: SubjectOrganization, SubjectID: org.ID, Mode: OverrideBoost, Amount: 5, ExpiresAt: &expires})
+	if got := limitNow(); got != 25 {
+		t.Fatalf("user limit plus org boost: limit = %d, want 25", got)
+	}
+	if _, err := tracker.RevokeOverride(ctx, 1, personal.ID, "ticket closed"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if got := limitNow(); got != 55 {
+		t.Fatalf("after revoking the user limit: limit = %d, want 55", got)
+	}
+
+	for name, in := range map[string]OverrideInput{
+		"boost without expiry": {SubjectType: SubjectUser, SubjectID: user.ID, Type: UsageProjects, Mode: OverrideBoost, Amount: 1, Reason: "x"},
+		"missing reason":       {SubjectType: SubjectUser, SubjectID: user.ID, Type: UsageProjects, Mode: OverrideLimit, Amount: 1},
+		"unknown usage type":   {SubjectType: SubjectUser, SubjectID: user.ID, Type: "gpus", Mode: OverrideLimit, Amount: 1, Reason: "x"},
+	} {
+		if _, err := tracker.CreateOverride(ctx, 1, in); !errors.Is(err, ErrInvalidOverride) {
+			t.Fatalf("%s: expected ErrInvalidOverride, got %v", name, err)
+		}
+	}
+	if _, err := tracker.CreateOverride(ctx, 1, OverrideInput{SubjectType: SubjectUser, SubjectID: 404, Type: UsageProjects, Mode: OverrideLimit, Amount: 1, Reason: "x"}); !errors.Is(err, ErrOverrideSubjectNotFound) {
+		t.Fatalf("unknown user: %v", err)
+	}
+}
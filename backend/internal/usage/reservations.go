@@ -0,0 +1,203 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// reservationGrace keeps a reservation alive past its execution timeout so
+// slow result collection doesn't release it early.
+const reservationGrace = time.Minute
+
+// reservationRetention is how long settled or expired reservations are kept.
+const reservationRetention = 24 * time.Hour
+
+// ExecutionReservation holds execution minutes against a user's daily budget
+// while an execution runs. It is settled with the measured time afterwards;
+// an unsettled reservation stops counting once it expires.
+type ExecutionReservation struct {
+	ID            string     `json:"id" gorm:"primaryKey;size:36"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	Minutes       int64      `json:"reserved_minutes" gorm:"not null"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"index"`
+	SettledAt     *time.Time `json:"settled_at,omitempty"`
+	ActualMinutes int64      `json:"actual_minutes"`
+	DurationMs    int64      `json:"duration_ms"`
+	CPUTimeMs     int64      `json:"cpu_time_ms"`
+}
+
+// TableName keeps the table name explicit.
+func (ExecutionReservation) TableName() string { return "execution_reservations" }
+
+// ExecutionQuotaError is returned when a reservation would exceed the
+// remaining execution budget.
+type ExecutionQuotaError struct {
+	Used      int64
+	Reserved  int64
+	Requested int64
+	Limit     int64
+}
+
+func (e *ExecutionQuotaError) Error() string {
+	return fmt.Sprintf("execution quota exceeded: %d used + %d reserved + %d requested > %d minutes", e.Used, e.Reserved, e.Requested, e.Limit)
+}
+
+// BillableExecutionMinutes converts a measured execution into whole minutes:
+// the larger of wall and CPU time (multi-core work can exceed wall time),
+// rounded up, with a one minute minimum.
+func BillableExecutionMinutes(durationMs, cpuTimeMs int64) int64 {
+	ms := durationMs
+	if cpuTimeMs > ms {
+		ms = cpuTimeMs
+	}
+	minutes := (ms + 59999) / 60000
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes
+}
+
+// EstimateExecutionMinutes reserves enough for an execution to hit its timeout.
+func EstimateExecutionMinutes(timeout time.Duration) int64 {
+	return BillableExecutionMinutes(timeout.Milliseconds(), 0)
+}
+
+// reservationLocks serializes reservations per user so concurrent requests
+// on one instance can't both claim the last minutes.
+var reservationLocks sync.Map
+
+func lockReservations(userID uint) func() {
+	v, _ := reservationLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// ReserveExecution holds minutes for an execution that may run up to timeout.
+// It fails with *ExecutionQuotaError when today's usage plus outstanding
+// reservations plus this one would exceed the daily limit, including any
+// quota overrides. Pass enforce=false for users who bypass billing; the
+// reservation is still made so their usage is settled the same way.
+func (t *Tracker) ReserveExecution(ctx context.Context, userID uint, plan PlanType, timeout time.Duration, enforce bool) (*ExecutionReservation, error) {
+	minutes := EstimateExecutionMinutes(timeout)
+	unlock := lockReservations(userID)
+	defer unlock()
+
+	if enforce {
+		// The current usage limit includes any quota overrides
+		current, err := t.GetCurrentUsage(ctx, userID, plan)
+		if err != nil {
+			return nil, err
+		}
+		if limit := int64(current.ExecutionLimit); limit != -1 {
+			reserved, err := t.reservedExecutionMinutes(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			used := int64(current.ExecutionMinutes)
+			if used+reserved+minutes > limit {
+				return nil, &ExecutionQuotaError{Used: used, Reserved: reserved, Requested: minutes, Limit: limit}
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	reservation := &ExecutionReservation{
+		ID:        uuid.New().String(),
+		CreatedAt: now,
+		UserID:    userID,
+		Minutes:   minutes,
+		ExpiresAt: now.Add(timeout + reservationGrace),
+	}
+	if err := t.db.WithContext(ctx).Create(reservation).Error; err != nil {
+		return nil, fmt.Errorf("failed to reserve execution minutes: %w", err)
+	}
+	return reservation, nil
+}
+
+// SettleExecution releases a reservation and records the measured usage. The
+// difference between reserved and actual minutes is returned to the budget.
+// Settling twice records nothing the second time.
+func (t *Tracker) SettleExecution(ctx context.Context, reservationID string, projectID *uint, durationMs, cpuTimeMs int64) (*ExecutionReservation, error) {
+	var reservation ExecutionReservation
+	if err := t.db.WithContext(ctx).First(&reservation, "id = ?", reservationID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load execution reservation: %w", err)
+	}
+	actual := BillableExecutionMinutes(durationMs, cpuTimeMs)
+	settled, err := t.closeReservation(ctx, &reservation, actual, durationMs, cpuTimeMs)
+	if err != nil || !settled {
+		return &reservation, err
+	}
+	err = t.RecordUsage(ctx, reservation.UserID, UsageExecutionMinutes, actual, projectID, map[string]interface{}{
+		"duration_ms":      durationMs,
+		"cpu_time_ms":      cpuTimeMs,
+		"reserved_minutes": reservation.Minutes,
+		"reservation_id":   reservation.ID,
+	})
+	return &reservation, err
+}
+
+// ReleaseExecution drops a reservation without charging, for executions that
+// never ran.
+func (t *Tracker) ReleaseExecution(ctx context.Context, reservationID string) error {
+	var reservation ExecutionReservation
+	if err := t.db.WithContext(ctx).First(&reservation, "id = ?", reservationID).Error; err != nil {
+		return fmt.Errorf("failed to load execution reservation: %w", err)
+	}
+	_, err := t.closeReservation(ctx, &reservation, 0, 0, 0)
+	return err
+}
+
+func (t *Tracker) closeReservation(ctx context.Context, r *ExecutionReservation, actual, durationMs, cpuTimeMs int64) (bool, error) {
+	now := time.Now().UTC()
+	res := t.db.WithContext(ctx).Model(&ExecutionReservation{}).
+		Where("id = ? AND settled_at IS NULL", r.ID).
+		Updates(map[string]interface{}{
+			"settled_at":     now,
+			"actual_minutes": actual,
+			"duration_ms":    durationMs,
+			"cpu_time_ms":    cpuTimeMs,
+		})
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to settle execution reservation: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	r.SettledAt = &now
+	r.ActualMinutes = actual
+	r.DurationMs = durationMs
+	r.CPUTimeMs = cpuTimeMs
+	return true, nil
+}
+
+// reservedExecutionMinutes sums the user's outstanding reservations.
+func (t *Tracker) reservedExecutionMinutes(ctx context.Context, userID uint) (int64, error) {
+	var reserved int64
+	err := t.db.WithContext(ctx).Model(&ExecutionReservation{}).
+		Select("COALESCE(SUM(minutes), 0)").
+		Where("user_id = ? AND settled_at IS NULL AND expires_at > ?", userID, time.Now().UTC()).
+		Scan(&reserved).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum execution reservations: %w", err)
+	}
+	return reserved, nil
+}
+
+// purgeReservations deletes reservations that were settled or expired more
+// than a day ago.
+func (t *Tracker) purgeReservations(ctx context.Context, now time.Time) (int64, error) {
+	cutoff := now.Add(-reservationRetention)
+	res := t.db.WithContext(ctx).
+		Where("created_at < ? AND (settled_at IS NOT NULL OR expires_at < ?)", cutoff, cutoff).
+		Delete(&ExecutionReservation{})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	return res.RowsAffected, nil
+}
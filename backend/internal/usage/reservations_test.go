@@ -0,0 +1,109 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBillableExecutionMinutesUsesLargerOfWallAndCPU(t *testing.T) {
+	cases := []struct {
+		durationMs, cpuTimeMs, want int64
+	}{
+		{0, 0, 1},
+		{1500, 200, 1},
+		{60000, 0, 1},
+		{60001, 0, 2},
+		{30000, 150000, 3},
+	}
+	for _, tc := range cases {
+		if got := BillableExecutionMinutes(tc.durationMs, tc.cpuTimeMs); got != tc.want {
+			t.Errorf("BillableExecutionMinutes(%d, %d) = %d, want %d", tc.durationMs, tc.cpuTimeMs, got, tc.want)
+		}
+	}
+	if got := EstimateExecutionMinutes(300 * time.Second); got != 5 {
+		t.Errorf("EstimateExecutionMinutes(300s) = %d, want 5", got)
+	}
+}
+
+func TestReserveExecutionCountsOutstandingReservations(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	ctx := context.Background()
+	limit := int64(GetPlanLimits(PlanFree).ExecutionMinutes)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if err := db.Create(&DailyUsageSummary{Date: today, UserID: 1, Type: UsageExecutionMinutes, Total: limit - 3}).Error; err != nil {
+		t.Fatalf("seed summary: %v", err)
+	}
+
+	first, err := tracker.ReserveExecution(ctx, 1, PlanFree, 2*time.Minute, true)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if first.Minutes != 2 {
+		t.Fatalf("reserved %d minutes, want 2", first.Minutes)
+	}
+
+	// 3 minutes remain, but 2 of them are held by the first run.
+	_, err = tracker.ReserveExecution(ctx, 1, PlanFree, 2*time.Minute, true)
+	var quotaErr *ExecutionQuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected ExecutionQuotaError, got %v", err)
+	}
+	if quotaErr.Used != limit-3 || quotaErr.Reserved != 2 || quotaErr.Requested != 2 || quotaErr.Limit != limit {
+		t.Fatalf("unexpected quota error: %+v", quotaErr)
+	}
+	allowed, _, _, err := tracker.CheckQuota(ctx, 1, PlanFree, UsageExecutionMinutes, 4)
+	if err != nil || allowed {
+		t.Fatalf("expected CheckQuota to count the reservation, allowed=%v err=%v", allowed, err)
+	}
+
+	// Users who bypass billing still get a reservation.
+	if _, err := tracker.ReserveExecution(ctx, 1, PlanFree, 10*time.Minute, false); err != nil {
+		t.Fatalf("unenforced reserve: %v", err)
+	}
+
+	if err := tracker.ReleaseExecution(ctx, first.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	reserved, err := tracker.reservedExecutionMinutes(ctx, 1)
+	if err != nil || reserved != 10 {
+		t.Fatalf("reserved = %d (%v), want 10 after release", reserved, err)
+	}
+}
+
+func TestSettleExecutionRecordsMeasuredMinutesOnce(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	ctx := context.Background()
+
+	reservation, err := tracker.ReserveExecution(ctx, 1, PlanPro, 5*time.Minute, true)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	settled, err := tracker.SettleExecution(ctx, reservation.ID, nil, 70000, 20000)
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	if settled.SettledAt == nil || settled.ActualMinutes != 2 {
+		t.Fatalf("unexpected settled reservation: %+v", settled)
+	}
+	if _, err := tracker.SettleExecution(ctx, reservation.ID, nil, 70000, 20000); err != nil {
+		t.Fatalf("second settle: %v", err)
+	}
+
+	var records []UsageRecord
+	db.Where("user_id = ? AND type = ?", 1, UsageExecutionMinutes).Find(&records)
+	if len(records) != 1 || records[0].Amount != 2 {
+		t.Fatalf("expected one 2-minute usage record, got %+v", records)
+	}
+	reserved, _ := tracker.reservedExecutionMinutes(ctx, 1)
+	if reserved != 0 {
+		t.Fatalf("expected settled reservation to stop counting, got %d", reserved)
+	}
+
+	// Expired and settled reservations are purged after the retention window.
+	purged, err := tracker.purgeReservations(ctx, time.Now().UTC().Add(reservationRetention+time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("purged %d (%v), want 1", purged, err)
+	}
+}
@@ -0,0 +1,408 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// DefaultRolloverInterval is how often the rollover job checks for a closed
+// billing period that still needs snapshots.
+const DefaultRolloverInterval = time.Hour
+
+// HistoryMonths is how many closed periods the history API returns by default.
+const HistoryMonths = 12
+
+// Snapshot subject types.
+const (
+	SubjectUser         = "user"
+	SubjectOrganization = "organization"
+)
+
+// UsagePeriodSnapshot freezes a user's or organization's usage at the end of
+// a billing period. AI requests and execution minutes are totals for the
+// period; projects and storage are point-in-time at rollover.
+type UsagePeriodSnapshot struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at"`
+	SubjectType string    `json:"subject_type" gorm:"uniqueIndex:idx_usage_snapshot_subject_period,priority:1;size:20;not null"`
+	SubjectID   uint      `json:"subject_id" gorm:"uniqueIndex:idx_usage_snapshot_subject_period,priority:2;not null"`
+	Period      string    `json:"period" gorm:"uniqueIndex:idx_usage_snapshot_subject_period,priority:3;size:7;not null"` // "2026-01"
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Plan        PlanType  `json:"plan" gorm:"size:50"`
+
+	Projects         int64 `json:"projects"`
+	StorageBytes     int64 `json:"storage_bytes"`
+	AIRequests       int64 `json:"ai_requests"`
+	ExecutionMinutes int64 `json:"execution_minutes"`
+
+	ProjectsLimit         int64 `json:"projects_limit"`
+	StorageLimit          int64 `json:"storage_limit"`
+	AIRequestsLimit       int64 `json:"ai_requests_limit"`       // Prorated when the plan changed mid-period
+	ExecutionMinutesLimit int64 `json:"execution_minutes_limit"` // Per day
+}
+
+// TableName keeps the table name explicit.
+func (UsagePeriodSnapshot) TableName() string { return "usage_period_snapshots" }
+
+// PlanChange records a subscription plan change so monthly limits can be
+// prorated across the period.
+type PlanChange struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	FromPlan  PlanType  `json:"from_plan" gorm:"size:50"`
+	ToPlan    PlanType  `json:"to_plan" gorm:"size:50"`
+	ChangedAt time.Time `json:"changed_at" gorm:"not null;index"`
+}
+
+// TableName keeps the table name explicit.
+func (PlanChange) TableName() string { return "usage_plan_changes" }
+
+// RolloverResult summarizes one rollover pass.
+type RolloverResult struct {
+	Period        string `json:"period"`
+	Users         int    `json:"users"`
+	Organizations int    `json:"organizations"`
+}
+
+// PeriodUsage is one billing period in the history API.
+type PeriodUsage struct {
+	UsagePeriodSnapshot
+	Current bool `json:"current"`
+}
+
+// periodBounds returns the UTC calendar month containing t as [start, end).
+func periodBounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// RecordPlanChange stores a plan change and drops cached usage so the
+// prorated limit applies immediately.
+func (t *Tracker) RecordPlanChange(ctx context.Context, userID uint, from, to PlanType, at time.Time) error {
+	if from == to {
+		return nil
+	}
+	change := &PlanChange{UserID: userID, FromPlan: from, ToPlan: to, ChangedAt: at.UTC()}
+	if err := t.db.WithContext(ctx).Create(change).Error; err != nil {
+		return fmt.Errorf("failed to record plan change: %w", err)
+	}
+	t.invalidateCache(userID)
+	return nil
+}
+
+// proratedAIRequestsLimit weights each plan's monthly AI request limit by the
+// share of [start, end) it was active. plan is the plan in effect now; if it
+// doesn't match the last recorded change (e.g. billing forced a downgrade),
+// its limit applies unprorated.
+func (t *Tracker) proratedAIRequestsLimit(ctx context.Context, userID uint, plan PlanType, start, end time.Time) (int, bool, error) {
+	limit := t.planLimits(plan).AIRequests
+	var changes []PlanChange
+	if err := t.db.WithContext(ctx).
+		Where("user_id = ? AND changed_at >= ? AND changed_at < ?", userID, start, end).
+		Order("changed_at ASC").Find(&changes).Error; err != nil {
+		return 0, false, err
+	}
+	if len(changes) == 0 || changes[len(changes)-1].ToPlan != plan || limit == -1 {
+		return limit, false, nil
+	}
+
+	total := end.Sub(start).Seconds()
+	weighted := 0.0
+	segmentStart := start
+	segmentPlan := changes[0].FromPlan
+	for _, change := range changes {
+		segmentLimit := t.planLimits(segmentPlan).AIRequests
+		if segmentLimit == -1 {
+			// Time spent unlimited doesn't carry over into a finite plan.
+			segmentLimit = 0
+		}
+		weighted += float64(segmentLimit) * change.ChangedAt.Sub(segmentStart).Seconds() / total
+		segmentStart = change.ChangedAt
+		segmentPlan = change.ToPlan
+	}
+	weighted += float64(limit) * end.Sub(segmentStart).Seconds() / total
+	return int(math.Ceil(weighted)), true, nil
+}
+
+// planAt returns the plan a user was on at the given time, walking back from
+// the current plan through any changes made since.
+func (t *Tracker) planAt(ctx context.Context, userID uint, current PlanType, at time.Time) (PlanType, error) {
+	var change PlanChange
+	err := t.db.WithContext(ctx).
+		Where("user_id = ? AND changed_at >= ?", userID, at).
+		Order("changed_at ASC").Limit(1).Find(&change).Error
+	if err != nil {
+		return "", err
+	}
+	if change.ID == 0 {
+		return current, nil
+	}
+	return change.FromPlan, nil
+}
+
+// RunRollover snapshots the most recently closed period for every user and
+// organization that doesn't have one yet. It is idempotent.
+func (t *Tracker) RunRollover(ctx context.Context, now time.Time) (*RolloverResult, error) {
+	currentStart, _ := periodBounds(now)
+	start, end := periodBounds(currentStart.AddDate(0, 0, -1))
+	period := start.Format("2006-01")
+	result := &RolloverResult{Period: period}
+
+	t.mu.RLock()
+	done := t.lastRolledPeriod == period
+	t.mu.RUnlock()
+	if done {
+		return result, nil
+	}
+
+	db := t.db.WithContext(ctx)
+	var existing []UsagePeriodSnapshot
+	if err := db.Select("subject_type", "subject_id").Where("period = ?", period).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to load existing snapshots: %w", err)
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, s := range existing {
+		seen[fmt.Sprintf("%s:%d", s.SubjectType, s.SubjectID)] = true
+	}
+
+	// Users with activity in the period or projects on the books.
+	var users []struct {
+		ID                 uint
+		SubscriptionType   string
+		SubscriptionStatus string
+	}
+	err := db.Table("users").Select("id", "subscription_type", "subscription_status").
+		Where("deleted_at IS NULL").
+		Where("id IN (?) OR id IN (?)",
+			db.Table("monthly_usage_summaries").Select("user_id").Where("month = ?", period),
+			db.Table("projects").Select("owner_id").Where("deleted_at IS NULL")).
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users for rollover: %w", err)
+	}
+	for _, u := range users {
+		if seen[fmt.Sprintf("%s:%d", SubjectUser, u.ID)] {
+			continue
+		}
+		plan := PlanType(u.SubscriptionType)
+		switch {
+		case plan == "":
+			plan = PlanFree
+		case u.SubscriptionStatus == "past_due" || u.SubscriptionStatus == "canceled":
+			plan = PlanFree
+		}
+		plan, err := t.planAt(ctx, u.ID, plan, end)
+		if err != nil {
+			return result, fmt.Errorf("failed to resolve plan for user %d: %w", u.ID, err)
+		}
+		snapshot, err := t.userSnapshot(ctx, u.ID, plan, period, start, end)
+		if err == nil {
+			err = t.saveSnapshot(ctx, snapshot)
+		}
+		if err != nil {
+			log.Printf("usage: rollover for user %d failed: %v", u.ID, err)
+			return result, err
+		}
+		result.Users++
+	}
+
+	if db.Migrator().HasTable("organizations") {
+		var orgs []struct {
+			ID               uint
+			SubscriptionType string
+			MaxProjects      int64
+			MaxStorageGB     float64
+			MaxAIRequests    int64
+		}
+		err := db.Table("organizations").
+			Select("id", "subscription_type", "max_projects", "max_storage_gb", "max_ai_requests").
+			Where("deleted_at IS NULL").Find(&orgs).Error
+		if err != nil {
+			return result, fmt.Errorf("failed to load organizations for rollover: %w", err)
+		}
+		for _, o := range orgs {
+			if seen[fmt.Sprintf("%s:%d", SubjectOrganization, o.ID)] {
+				continue
+			}
+			snapshot := &UsagePeriodSnapshot{
+				SubjectType:           SubjectOrganization,
+				SubjectID:             o.ID,
+				Period:                period,
+				PeriodStart:           start,
+				PeriodEnd:             end,
+				Plan:                  PlanType(o.SubscriptionType),
+				ProjectsLimit:         o.MaxProjects,
+				StorageLimit:          int64(o.MaxStorageGB * 1024 * 1024 * 1024),
+				AIRequestsLimit:       o.MaxAIRequests,
+				ExecutionMinutesLimit: int64(executionMinutesForPlan(PlanType(o.SubscriptionType))),
+			}
+			if err := t.fillOrgUsage(ctx, snapshot); err == nil {
+				err = t.saveSnapshot(ctx, snapshot)
+			}
+			if err != nil {
+				log.Printf("usage: rollover for organization %d failed: %v", o.ID, err)
+				return result, err
+			}
+			result.Organizations++
+		}
+	}
+
+	t.mu.Lock()
+	t.lastRolledPeriod = period
+	t.mu.Unlock()
+	return result, nil
+}
+
+func (t *Tracker) userSnapshot(ctx context.Context, userID uint, plan PlanType, period string, start, end time.Time) (*UsagePeriodSnapshot, error) {
+	current, err := t.calculateCurrentUsage(ctx, userID, plan)
+	if err != nil {
+		return nil, err
+	}
+	aiRequests, _, err := t.lookupMonthlySummary(ctx, userID, UsageAIRequests, period)
+	if err != nil {
+		return nil, err
+	}
+	execMinutes, _, err := t.lookupMonthlySummary(ctx, userID, UsageExecutionMinutes, period)
+	if err != nil {
+		return nil, err
+	}
+	aiLimit, _, err := t.proratedAIRequestsLimit(ctx, userID, plan, start, end)
+	if err != nil {
+		return nil, err
+	}
+	limits := GetPlanLimits(plan)
+	return &UsagePeriodSnapshot{
+		SubjectType:           SubjectUser,
+		SubjectID:             userID,
+		Period:                period,
+		PeriodStart:           start,
+		PeriodEnd:             end,
+		Plan:                  plan,
+		Projects:              int64(current.Projects),
+		StorageBytes:          current.StorageBytes,
+		AIRequests:            aiRequests,
+		ExecutionMinutes:      execMinutes,
+		ProjectsLimit:         int64(limits.Projects),
+		StorageLimit:          limits.StorageBytes,
+		AIRequestsLimit:       int64(aiLimit),
+		ExecutionMinutesLimit: int64(limits.ExecutionMinutes),
+	}, nil
+}
+
+// fillOrgUsage totals usage attributed to the organization's projects.
+func (t *Tracker) fillOrgUsage(ctx context.Context, s *UsagePeriodSnapshot) error {
+	db := t.db.WithContext(ctx)
+	orgProjects := db.Table("projects").Select("id").Where("organization_id = ?", s.SubjectID)
+
+	if err := db.Table("projects").
+		Where("organization_id = ? AND deleted_at IS NULL AND (is_archived IS NULL OR is_archived = ?)", s.SubjectID, false).
+		Count(&s.Projects).Error; err != nil {
+		return fmt.Errorf("failed to count organization projects: %w", err)
+	}
+	if err := db.Table("files").Select("COALESCE(SUM(size), 0)").
+		Where("deleted_at IS NULL AND project_id IN (?)",
+			db.Table("projects").Select("id").Where("organization_id = ? AND deleted_at IS NULL", s.SubjectID)).
+		Scan(&s.StorageBytes).Error; err != nil {
+		return fmt.Errorf("failed to sum organization storage: %w", err)
+	}
+
+	var totals []struct {
+		Type  UsageType
+		Total int64
+	}
+	if err := db.Model(&UsageRecord{}).Select("type, COALESCE(SUM(amount), 0) AS total").
+		Where("project_id IN (?) AND created_at >= ? AND created_at < ?", orgProjects, s.PeriodStart, s.PeriodEnd).
+		Where("type IN ?", []UsageType{UsageAIRequests, UsageExecutionMinutes}).
+		Group("type").Scan(&totals).Error; err != nil {
+		return fmt.Errorf("failed to total organization usage: %w", err)
+	}
+	for _, row := range totals {
+		switch row.Type {
+		case UsageAIRequests:
+			s.AIRequests = row.Total
+		case UsageExecutionMinutes:
+			s.ExecutionMinutes = row.Total
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) saveSnapshot(ctx context.Context, s *UsagePeriodSnapshot) error {
+	return t.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(s).Error
+}
+
+// GetPeriodHistory returns up to months closed-period snapshots for a user or
+// organization, newest first.
+func (t *Tracker) GetPeriodHistory(ctx context.Context, subjectType string, subjectID uint, months int) ([]UsagePeriodSnapshot, error) {
+	if months <= 0 {
+		months = HistoryMonths
+	}
+	var snapshots []UsagePeriodSnapshot
+	err := t.db.WithContext(ctx).
+		Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).
+		Order("period DESC").Limit(months).Find(&snapshots).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get period history: %w", err)
+	}
+	return snapshots, nil
+}
+
+// GetUserPeriodHistory returns the in-progress period followed by up to months
+// closed periods for a user.
+func (t *Tracker) GetUserPeriodHistory(ctx context.Context, userID uint, plan PlanType, months int, now time.Time) ([]PeriodUsage, error) {
+	closed, err := t.GetPeriodHistory(ctx, SubjectUser, userID, months)
+	if err != nil {
+		return nil, err
+	}
+	start, end := periodBounds(now)
+	current, err := t.userSnapshot(ctx, userID, plan, start.Format("2006-01"), start, end)
+	if err != nil {
+		return nil, err
+	}
+	periods := make([]PeriodUsage, 0, len(closed)+1)
+	periods = append(periods, PeriodUsage{UsagePeriodSnapshot: *current, Current: true})
+	for _, s := range closed {
+		periods = append(periods, PeriodUsage{UsagePeriodSnapshot: s})
+	}
+	return periods, nil
+}
+
+// StartRollover launches the periodic rollover job.
+func (t *Tracker) StartRollover(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRolloverInterval
+	}
+	go func() {
+		run := func() {
+			result, err := t.RunRollover(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("usage: rollover run failed: %v", err)
+				return
+			}
+			if result.Users > 0 || result.Organizations > 0 {
+				log.Printf("usage: rolled over %s for %d users and %d organizations", result.Period, result.Users, result.Organizations)
+			}
+			if _, err := t.purgeReservations(ctx, time.Now().UTC()); err != nil {
+				log.Printf("usage: execution reservation purge failed: %v", err)
+			}
+		}
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+}
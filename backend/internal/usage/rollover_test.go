@@ -0,0 +1,136 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRolloverTest(t *testing.T) (*Tracker, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.File{}); err != nil {
+		t.Fatalf("migrate models: %v", err)
+	}
+	tracker := NewTracker(db, nil)
+	if err := tracker.Migrate(); err != nil {
+		t.Fatalf("migrate usage: %v", err)
+	}
+	return tracker, db
+}
+
+func TestProratedAIRequestsLimitBlendsPlansAcrossPeriod(t *testing.T) {
+	tracker, _ := setupRolloverTest(t)
+	// The launch catalog meters AI requests through credits, so use a
+	// catalog with finite monthly limits.
+	catalog := map[PlanType]int{PlanFree: 100, PlanPro: 1000, PlanEnterprise: -1}
+	tracker.planLimits = func(plan PlanType) PlanLimits { return PlanLimits{AIRequests: catalog[plan]} }
+	ctx := context.Background()
+	start := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	midpoint := start.Add(end.Sub(start) / 2)
+
+	if err := tracker.RecordPlanChange(ctx, 1, PlanFree, PlanPro, midpoint); err != nil {
+		t.Fatalf("record plan change: %v", err)
+	}
+
+	limit, prorated, err := tracker.proratedAIRequestsLimit(ctx, 1, PlanPro, start, end)
+	if err != nil {
+		t.Fatalf("prorate: %v", err)
+	}
+	free, pro := catalog[PlanFree], catalog[PlanPro]
+	if want := (free + pro) / 2; !prorated || limit != want {
+		t.Fatalf("limit = %d (prorated=%v), want %d", limit, prorated, want)
+	}
+
+	// A plan that doesn't match the last change (e.g. forced to free for
+	// past_due) keeps its own limit.
+	limit, prorated, err = tracker.proratedAIRequestsLimit(ctx, 1, PlanFree, start, end)
+	if err != nil {
+		t.Fatalf("prorate: %v", err)
+	}
+	if prorated || limit != free {
+		t.Fatalf("limit = %d (prorated=%v), want unprorated %d", limit, prorated, free)
+	}
+
+	// Switching to an unlimited plan stays unlimited.
+	if err := tracker.RecordPlanChange(ctx, 1, PlanPro, PlanEnterprise, midpoint.Add(time.Hour)); err != nil {
+		t.Fatalf("record plan change: %v", err)
+	}
+	if limit, _, _ = tracker.proratedAIRequestsLimit(ctx, 1, PlanEnterprise, start, end); limit != -1 {
+		t.Fatalf("limit = %d, want unlimited", limit)
+	}
+
+	// Changes outside the period don't prorate.
+	limit, prorated, _ = tracker.proratedAIRequestsLimit(ctx, 1, PlanPro, end, end.AddDate(0, 1, 0))
+	if prorated || limit != pro {
+		t.Fatalf("limit = %d (prorated=%v), want %d", limit, prorated, pro)
+	}
+}
+
+func TestRunRolloverSnapshotsClosedPeriodOnce(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	ctx := context.Background()
+
+	user := models.User{Username: "roller", Email: "roller@example.com", PasswordHash: "x", SubscriptionType: "pro", SubscriptionStatus: "active"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	project := models.Project{Name: "p", Language: "go", OwnerID: user.ID}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	for _, s := range []MonthlyUsageSummary{
+		{Month: "2026-03", UserID: user.ID, Type: UsageAIRequests, Total: 42},
+		{Month: "2026-03", UserID: user.ID, Type: UsageExecutionMinutes, Total: 7},
+		{Month: "2026-04", UserID: user.ID, Type: UsageAIRequests, Total: 99},
+	} {
+		if err := db.Create(&s).Error; err != nil {
+			t.Fatalf("seed summary: %v", err)
+		}
+	}
+	// Upgraded from builder to pro after March closed.
+	if err := tracker.RecordPlanChange(ctx, user.ID, PlanBuilder, PlanPro, time.Date(2026, time.April, 3, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("record plan change: %v", err)
+	}
+
+	now := time.Date(2026, time.April, 2, 12, 0, 0, 0, time.UTC)
+	result, err := tracker.RunRollover(ctx, now)
+	if err != nil {
+		t.Fatalf("rollover: %v", err)
+	}
+	if result.Period != "2026-03" || result.Users != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	history, err := tracker.GetPeriodHistory(ctx, SubjectUser, user.ID, 0)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("expected one snapshot, got %d (%v)", len(history), err)
+	}
+	snap := history[0]
+	if snap.AIRequests != 42 || snap.ExecutionMinutes != 7 || snap.Projects != 1 {
+		t.Fatalf("unexpected snapshot usage: %+v", snap)
+	}
+	if snap.Plan != PlanBuilder || snap.AIRequestsLimit != int64(GetPlanLimits(PlanBuilder).AIRequests) {
+		t.Fatalf("expected March snapshot on builder limits, got plan=%s limit=%d", snap.Plan, snap.AIRequestsLimit)
+	}
+
+	// A second pass (fresh tracker, so no in-memory marker) adds nothing.
+	again, err := NewTracker(db, nil).RunRollover(ctx, now)
+	if err != nil || again.Users != 0 {
+		t.Fatalf("expected idempotent rollover, got %+v (%v)", again, err)
+	}
+	var count int64
+	db.Model(&UsagePeriodSnapshot{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 snapshot row, got %d", count)
+	}
+}
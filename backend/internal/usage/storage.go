@@ -0,0 +1,311 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// DefaultStorageReconcileInterval is how often per-project storage is
+// recomputed from the files table.
+const DefaultStorageReconcileInterval = 6 * time.Hour
+
+// ProjectStorageUsage is the reconciled storage rollup for one project,
+// including projects and files sitting in the trash.
+type ProjectStorageUsage struct {
+	ProjectID      uint      `json:"project_id" gorm:"primaryKey;autoIncrement:false"`
+	OwnerID        uint      `json:"owner_id" gorm:"not null;index"`
+	OrganizationID *uint     `json:"organization_id,omitempty" gorm:"index"`
+	FileCount      int64     `json:"file_count"`
+	Bytes          int64     `json:"bytes"`
+	TrashBytes     int64     `json:"trash_bytes"`
+	ReconciledAt   time.Time `json:"reconciled_at" gorm:"index"`
+}
+
+// TableName keeps the table name explicit.
+func (ProjectStorageUsage) TableName() string { return "project_storage_usage" }
+
+// StorageReconcileResult summarizes one reconciliation pass.
+type StorageReconcileResult struct {
+	FilesCorrected int64 `json:"files_corrected"`
+	Projects       int   `json:"projects"`
+	UsersChanged   int   `json:"users_changed"`
+}
+
+// ProjectStorage is one project's share of a user's storage quota.
+type ProjectStorage struct {
+	ProjectID  uint    `json:"project_id"`
+	Name       string  `json:"name"`
+	Trashed    bool    `json:"trashed"`
+	FileCount  int64   `json:"file_count"`
+	Bytes      int64   `json:"bytes"`
+	TrashBytes int64   `json:"trash_bytes"`
+	Billable   int64   `json:"billable_bytes"`
+	Percentage float64 `json:"percentage"`
+}
+
+// StorageBreakdown lists which projects consume a user's storage quota.
+type StorageBreakdown struct {
+	Plan         PlanType         `json:"plan"`
+	TotalBytes   int64            `json:"total_bytes"`
+	LimitBytes   int64            `json:"limit_bytes"`
+	TrashWeight  float64          `json:"trash_weight"`
+	Projects     []ProjectStorage `json:"projects"`
+	ReconciledAt *time.Time       `json:"reconciled_at,omitempty"`
+}
+
+// projectStorageRow is the per-project aggregate over the files table.
+type projectStorageRow struct {
+	ProjectID      uint
+	OwnerID        uint
+	OrganizationID *uint
+	Name           string
+	Trashed        bool
+	FileCount      int64
+	Bytes          int64
+	TrashBytes     int64
+}
+
+// projectStorageQuery aggregates live and trashed file bytes per project.
+// Raw SQL so soft-deleted projects and files are included.
+const projectStorageQuery = `
+	SELECT p.id AS project_id, p.owner_id, p.organization_id, p.name,
+		(p.deleted_at IS NOT NULL) AS trashed,
+		COALESCE(SUM(CASE WHEN f.id IS NOT NULL AND p.deleted_at IS NULL AND f.deleted_at IS NULL THEN 1 ELSE 0 END), 0) AS file_count,
+		COALESCE(SUM(CASE WHEN p.deleted_at IS NULL AND f.deleted_at IS NULL THEN f.size ELSE 0 END), 0) AS bytes,
+		COALESCE(SUM(CASE WHEN p.deleted_at IS NOT NULL OR f.deleted_at IS NOT NULL THEN f.size ELSE 0 END), 0) AS trash_bytes
+	FROM projects p
+	LEFT JOIN files f ON f.project_id = p.id
+`
+
+// contentLengthExpr returns the SQL for a file's content size in bytes.
+func (t *Tracker) contentLengthExpr() string {
+	if t.db.Dialector.Name() == "postgres" {
+		return "OCTET_LENGTH(content)"
+	}
+	return "LENGTH(CAST(content AS BLOB))"
+}
+
+// ReconcileStorage recomputes storage from the files table: it corrects file
+// rows whose size drifted from their content, rewrites the per-project rollup
+// and drops cached usage for owners whose totals changed.
+func (t *Tracker) ReconcileStorage(ctx context.Context, now time.Time) (*StorageReconcileResult, error) {
+	result := &StorageReconcileResult{}
+	db := t.db.WithContext(ctx)
+
+	length := t.contentLengthExpr()
+	fix := db.Exec(fmt.Sprintf(`
+		UPDATE files SET size = %s
+		WHERE type <> 'directory' AND content IS NOT NULL AND content <> '' AND size <> %s
+	`, length, length))
+	if fix.Error != nil {
+		return nil, fmt.Errorf("failed to correct file sizes: %w", fix.Error)
+	}
+	result.FilesCorrected = fix.RowsAffected
+
+	var previous []ProjectStorageUsage
+	if err := db.Find(&previous).Error; err != nil {
+		return nil, fmt.Errorf("failed to load storage rollup: %w", err)
+	}
+	previousBytes := make(map[uint]int64, len(previous))
+	previousOwner := make(map[uint]uint, len(previous))
+	for _, p := range previous {
+		previousBytes[p.ProjectID] = p.Bytes + p.TrashBytes
+		previousOwner[p.ProjectID] = p.OwnerID
+	}
+
+	var rows []projectStorageRow
+	if err := db.Raw(projectStorageQuery + `
+		GROUP BY p.id, p.owner_id, p.organization_id, p.name, p.deleted_at
+	`).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate project storage: %w", err)
+	}
+
+	changed := make(map[uint]bool)
+	for _, row := range rows {
+		usage := ProjectStorageUsage{
+			ProjectID:      row.ProjectID,
+			OwnerID:        row.OwnerID,
+			OrganizationID: row.OrganizationID,
+			FileCount:      row.FileCount,
+			Bytes:          row.Bytes,
+			TrashBytes:     row.TrashBytes,
+			ReconciledAt:   now,
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"owner_id", "organization_id", "file_count", "bytes", "trash_bytes", "reconciled_at"}),
+		}).Create(&usage).Error; err != nil {
+			return nil, fmt.Errorf("failed to save storage rollup for project %d: %w", row.ProjectID, err)
+		}
+		if prev, ok := previousBytes[row.ProjectID]; !ok || prev != row.Bytes+row.TrashBytes || previousOwner[row.ProjectID] != row.OwnerID {
+			changed[row.OwnerID] = true
+			if owner, ok := previousOwner[row.ProjectID]; ok {
+				changed[owner] = true
+			}
+		}
+		delete(previousOwner, row.ProjectID)
+	}
+	result.Projects = len(rows)
+
+	// Projects purged since the last pass.
+	for projectID, owner := range previousOwner {
+		changed[owner] = true
+		if err := db.Delete(&ProjectStorageUsage{}, "project_id = ?", projectID).Error; err != nil {
+			return nil, fmt.Errorf("failed to drop storage rollup for project %d: %w", projectID, err)
+		}
+	}
+
+	for userID := range changed {
+		t.invalidateCache(userID)
+	}
+	result.UsersChanged = len(changed)
+
+	// Organization projects are charged to the organization, not their creator
+	if db.Migrator().HasTable("organizations") {
+		orgBytes := make(map[uint]int64)
+		for _, row := range rows {
+			if row.OrganizationID != nil {
+				orgBytes[*row.OrganizationID] += row.Bytes
+			}
+		}
+		if err := db.Exec("UPDATE organizations SET used_storage_bytes = 0 WHERE used_storage_bytes <> 0").Error; err != nil {
+			return nil, fmt.Errorf("failed to reset organization storage: %w", err)
+		}
+		for orgID, bytes := range orgBytes {
+			if err := db.Exec("UPDATE organizations SET used_storage_bytes = ? WHERE id = ?", bytes, orgID).Error; err != nil {
+				return nil, fmt.Errorf("failed to save storage for organization %d: %w", orgID, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// CheckOrgStorageQuota checks whether the organization's projects can grow
+// by additional bytes within its max_storage_gb. Trash is not billed, as on
+// the Team plan. A limit of -1 means unlimited.
+func (t *Tracker) CheckOrgStorageQuota(ctx context.Context, orgID uint, additional int64) (allowed bool, current int64, limit int64, err error) {
+	db := t.db.WithContext(ctx)
+	var maxGB float64
+	if err := db.Table("organizations").Select("max_storage_gb").Where("id = ?", orgID).Scan(&maxGB).Error; err != nil {
+		return false, 0, 0, fmt.Errorf("failed to load organization storage limit: %w", err)
+	}
+	if err := db.Raw(`
+		SELECT COALESCE(SUM(f.size), 0)
+		FROM files f
+		JOIN projects p ON f.project_id = p.id
+		WHERE p.organization_id = ? AND p.deleted_at IS NULL AND f.deleted_at IS NULL
+	`, orgID).Scan(&current).Error; err != nil {
+		return false, 0, 0, fmt.Errorf("failed to sum organization storage: %w", err)
+	}
+	if maxGB <= 0 {
+		return true, current, -1, nil
+	}
+	limit = int64(maxGB * (1 << 30))
+	return current+additional <= limit, current, limit, nil
+}
+
+// CheckProjectStorageQuota checks a write to a project against whoever pays
+// for its storage: the organization for organization projects, otherwise
+// the user's plan. orgID is the paying organization, or nil.
+func (t *Tracker) CheckProjectStorageQuota(ctx context.Context, userID uint, plan PlanType, projectID uint, additional int64) (allowed bool, current int64, limit int64, orgID *uint, err error) {
+	if err := t.db.WithContext(ctx).Table("projects").Select("organization_id").Where("id = ?", projectID).Scan(&orgID).Error; err != nil {
+		return false, 0, 0, nil, fmt.Errorf("failed to load project owner: %w", err)
+	}
+	if orgID != nil {
+		allowed, current, limit, err = t.CheckOrgStorageQuota(ctx, *orgID, additional)
+		return allowed, current, limit, orgID, err
+	}
+	allowed, current, limit, err = t.CheckQuota(ctx, userID, plan, UsageStorageBytes, additional)
+	return allowed, current, limit, nil, err
+}
+
+// GetStorageBreakdown lists the user's personal projects by the storage they
+// consume, counted the same way as the storage quota: live bytes plus trash
+// weighted by plan.
+func (t *Tracker) GetStorageBreakdown(ctx context.Context, userID uint, plan PlanType) (*StorageBreakdown, error) {
+	var rows []projectStorageRow
+	if err := t.db.WithContext(ctx).Raw(projectStorageQuery+`
+		WHERE p.owner_id = ? AND p.organization_id IS NULL
+		GROUP BY p.id, p.owner_id, p.organization_id, p.name, p.deleted_at
+	`, userID).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate project storage: %w", err)
+	}
+
+	breakdown := &StorageBreakdown{
+		Plan:        plan,
+		LimitBytes:  t.planLimits(plan).StorageBytes,
+		TrashWeight: TrashStorageWeight(plan),
+		Projects:    make([]ProjectStorage, 0, len(rows)),
+	}
+	for _, row := range rows {
+		billable := row.Bytes + int64(float64(row.TrashBytes)*breakdown.TrashWeight)
+		breakdown.TotalBytes += billable
+		breakdown.Projects = append(breakdown.Projects, ProjectStorage{
+			ProjectID:  row.ProjectID,
+			Name:       row.Name,
+			Trashed:    row.Trashed,
+			FileCount:  row.FileCount,
+			Bytes:      row.Bytes,
+			TrashBytes: row.TrashBytes,
+			Billable:   billable,
+		})
+	}
+	for i := range breakdown.Projects {
+		if breakdown.TotalBytes > 0 {
+			breakdown.Projects[i].Percentage = float64(breakdown.Projects[i].Billable) / float64(breakdown.TotalBytes) * 100
+		}
+	}
+	sort.SliceStable(breakdown.Projects, func(i, j int) bool {
+		return breakdown.Projects[i].Billable > breakdown.Projects[j].Billable
+	})
+
+	var last ProjectStorageUsage
+	err := t.db.WithContext(ctx).
+		Where("owner_id = ? AND organization_id IS NULL", userID).
+		Order("reconciled_at DESC").
+		Limit(1).
+		Find(&last).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage rollup: %w", err)
+	}
+	if last.ProjectID != 0 {
+		breakdown.ReconciledAt = &last.ReconciledAt
+	}
+	return breakdown, nil
+}
+
+// StartStorageReconciler reconciles storage immediately and then on every
+// interval until ctx is cancelled.
+func (t *Tracker) StartStorageReconciler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultStorageReconcileInterval
+	}
+	go func() {
+		run := func() {
+			result, err := t.ReconcileStorage(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("usage: storage reconciliation failed: %v", err)
+				return
+			}
+			if result.FilesCorrected > 0 || result.UsersChanged > 0 {
+				log.Printf("usage: reconciled storage for %d projects (%d file sizes corrected, %d users changed)", result.Projects, result.FilesCorrected, result.UsersChanged)
+			}
+		}
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+}
@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"apex-build/pkg/models"
+)
+
+func TestReconcileStorageCorrectsSizesAndRollsUpProjects(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	ctx := context.Background()
+
+	user := models.User{Username: "store", Email: "store@example.com", PasswordHash: "x", SubscriptionType: "pro"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	big := models.Project{Name: "big", Language: "go", OwnerID: user.ID}
+	small := models.Project{Name: "small", Language: "go", OwnerID: user.ID}
+	for _, p := range []*models.Project{&big, &small} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+	}
+	files := []models.File{
+		// Size drifted from the stored content.
+		{ProjectID: big.ID, Path: "main.go", Name: "main.go", Type: "file", Content: "package main\n", Size: 1},
+		{ProjectID: big.ID, Path: "data.txt", Name: "data.txt", Type: "file", Content: "0123456789", Size: 10},
+		{ProjectID: big.ID, Path: "src", Name: "src", Type: "directory"},
+		{ProjectID: small.ID, Path: "a.txt", Name: "a.txt", Type: "file", Content: "abc", Size: 3},
+		{ProjectID: small.ID, Path: "old.txt", Name: "old.txt", Type: "file", Content: "trashed!", Size: 8},
+	}
+	for i := range files {
+		if err := db.Create(&files[i]).Error; err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+	}
+	if err := db.Delete(&files[4]).Error; err != nil {
+		t.Fatalf("trash file: %v", err)
+	}
+
+	now := time.Date(2026, time.May, 1, 6, 0, 0, 0, time.UTC)
+	result, err := tracker.ReconcileStorage(ctx, now)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if result.FilesCorrected != 1 || result.Projects != 2 || result.UsersChanged != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	var rollup ProjectStorageUsage
+	if err := db.First(&rollup, "project_id = ?", big.ID).Error; err != nil {
+		t.Fatalf("load rollup: %v", err)
+	}
+	if rollup.Bytes != 23 || rollup.FileCount != 3 || rollup.TrashBytes != 0 {
+		t.Fatalf("unexpected rollup for big project: %+v", rollup)
+	}
+	var smallRollup ProjectStorageUsage
+	db.First(&smallRollup, "project_id = ?", small.ID)
+	if smallRollup.Bytes != 3 || smallRollup.TrashBytes != 8 || smallRollup.FileCount != 1 {
+		t.Fatalf("unexpected rollup for small project: %+v", smallRollup)
+	}
+
+	// Nothing changed, so a second pass touches no users.
+	again, err := tracker.ReconcileStorage(ctx, now.Add(time.Hour))
+	if err != nil || again.FilesCorrected != 0 || again.UsersChanged != 0 {
+		t.Fatalf("expected a quiet second pass, got %+v (%v)", again, err)
+	}
+
+	breakdown, err := tracker.GetStorageBreakdown(ctx, user.ID, PlanPro)
+	if err != nil {
+		t.Fatalf("breakdown: %v", err)
+	}
+	if len(breakdown.Projects) != 2 || breakdown.Projects[0].ProjectID != big.ID {
+		t.Fatalf("expected big project first, got %+v", breakdown.Projects)
+	}
+	wantSmall := 3 + int64(8*TrashStorageWeight(PlanPro))
+	if breakdown.Projects[1].Billable != wantSmall || breakdown.TotalBytes != 23+wantSmall {
+		t.Fatalf("unexpected billable bytes: %+v total=%d", breakdown.Projects, breakdown.TotalBytes)
+	}
+	current, err := tracker.GetCurrentUsage(ctx, user.ID, PlanPro)
+	if err != nil {
+		t.Fatalf("current usage: %v", err)
+	}
+	if current.StorageBytes != breakdown.TotalBytes {
+		t.Fatalf("breakdown total %d disagrees with quota usage %d", breakdown.TotalBytes, current.StorageBytes)
+	}
+	if breakdown.ReconciledAt == nil || !breakdown.ReconciledAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected reconciled_at: %v", breakdown.ReconciledAt)
+	}
+}
+
+func TestCheckProjectStorageQuotaChargesOrganizationProjects(t *testing.T) {
+	tracker, db := setupRolloverTest(t)
+	ctx := context.Background()
+	if err := db.Exec("CREATE TABLE organizations (id integer primary key, max_storage_gb real, used_storage_bytes integer)").Error; err != nil {
+		t.Fatalf("create organizations: %v", err)
+	}
+	db.Exec("INSERT INTO organizations (id, max_storage_gb) VALUES (1, ?)", 1.0/(1<<30)*100)
+
+	user := models.User{Username: "member", Email: "member@example.com", PasswordHash: "x", SubscriptionType: "pro"}
+	db.Create(&user)
+	orgID := uint(1)
+	orgProject := models.Project{Name: "team", Language: "go", OwnerID: user.ID, OrganizationID: &orgID}
+	personal := models.Project{Name: "mine", Language: "go", OwnerID: user.ID}
+	db.Create(&orgProject)
+	db.Create(&personal)
+	db.Create(&models.File{ProjectID: orgProject.ID, Path: "a.txt", Name: "a.txt", Type: "file", Content: "x", Size: 60})
+
+	allowed, current, limit, payer, err := tracker.CheckProjectStorageQuota(ctx, user.ID, PlanPro, orgProject.ID, 50)
+	if err != nil {
+		t.Fatalf("check org project: %v", err)
+	}
+	if allowed || current != 60 || limit != 100 || payer == nil || *payer != orgID {
+		t.Fatalf("expected the org's 100 byte limit to refuse: allowed=%v current=%d limit=%d payer=%v", allowed, current, limit, payer)
+	}
+
+	allowed, current, _, payer, err = tracker.CheckProjectStorageQuota(ctx, user.ID, PlanPro, personal.ID, 50)
+	if err != nil || !allowed || payer != nil || current != 0 {
+		t.Fatalf("personal project should use the plan and exclude org bytes: allowed=%v current=%d payer=%v err=%v", allowed, current, payer, err)
+	}
+}
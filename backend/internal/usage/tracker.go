@@ -71,6 +71,7 @@ type PlanLimits struct {
 	StorageBytes     int64 `json:"storage_bytes"`     // Max storage in bytes
 	AIRequests       int   `json:"ai_requests"`       // Max AI requests per month
 	ExecutionMinutes int   `json:"execution_minutes"` // Max execution minutes per day
+	MaxFileBytes     int64 `json:"max_file_bytes"`    // Max size of a single project file
 }
 
 // GetPlanLimits returns the limits for a given plan.
@@ -83,7 +84,7 @@ func GetPlanLimits(plan PlanType) PlanLimits {
 	pLimits := paymentsGetPlanLimits(paymentsPlanType)
 	if pLimits == nil {
 		// Fallback: free tier
-		return PlanLimits{Projects: 3, StorageBytes: 1 * 1024 * 1024 * 1024, AIRequests: 1000, ExecutionMinutes: 10}
+		return PlanLimits{Projects: 3, StorageBytes: 1 * 1024 * 1024 * 1024, AIRequests: 1000, ExecutionMinutes: 10, MaxFileBytes: maxFileBytesForPlan(PlanFree)}
 	}
 
 	storageBytes := int64(pLimits.StorageGB) * 1024 * 1024 * 1024
@@ -100,6 +101,7 @@ func GetPlanLimits(plan PlanType) PlanLimits {
 		StorageBytes:     storageBytes,
 		AIRequests:       pLimits.AIRequestsPerMonth,
 		ExecutionMinutes: execMinutes,
+		MaxFileBytes:     maxFileBytesForPlan(plan),
 	}
 }
 
@@ -123,6 +125,41 @@ func executionMinutesForPlan(plan PlanType) int {
 	}
 }
 
+// maxFileBytesForPlan caps the size of a single uploaded file. Uploads above
+// a few MB go through the resumable upload API, so these bound datasets and
+// media assets rather than request bodies.
+func maxFileBytesForPlan(plan PlanType) int64 {
+	const mb = 1024 * 1024
+	switch plan {
+	case PlanBuilder:
+		return 100 * mb
+	case PlanPro:
+		return 500 * mb
+	case PlanTeam:
+		return 1024 * mb
+	case PlanEnterprise, PlanOwner:
+		return 5 * 1024 * mb
+	default:
+		return 25 * mb
+	}
+}
+
+// TrashStorageWeight is the fraction of trashed bytes that counts against the
+// storage quota. Free tiers pay full price for trash so it can't be used to
+// park data; paid tiers get it discounted or free.
+func TrashStorageWeight(plan PlanType) float64 {
+	switch plan {
+	case PlanFree, PlanBuilder:
+		return 1
+	case PlanPro:
+		return 0.5
+	case PlanTeam, PlanEnterprise, PlanOwner:
+		return 0
+	default:
+		return 1
+	}
+}
+
 // UsageRecord represents a single usage event stored in the database
 type UsageRecord struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
@@ -156,19 +193,24 @@ type MonthlyUsageSummary struct {
 
 // CurrentUsage represents the user's current usage snapshot
 type CurrentUsage struct {
-	UserID           uint      `json:"user_id"`
-	Plan             PlanType  `json:"plan"`
-	Projects         int       `json:"projects"`
-	ProjectsLimit    int       `json:"projects_limit"`
-	StorageBytes     int64     `json:"storage_bytes"`
-	StorageLimit     int64     `json:"storage_limit"`
-	AIRequests       int       `json:"ai_requests"` // This month
-	AIRequestsLimit  int       `json:"ai_requests_limit"`
-	ExecutionMinutes int       `json:"execution_minutes"` // Today
-	ExecutionLimit   int       `json:"execution_limit"`
-	PeriodStart      time.Time `json:"period_start"`
-	PeriodEnd        time.Time `json:"period_end"`
-	CachedAt         time.Time `json:"cached_at"`
+	UserID             uint      `json:"user_id"`
+	Plan               PlanType  `json:"plan"`
+	Projects           int       `json:"projects"`
+	ProjectsLimit      int       `json:"projects_limit"`
+	StorageBytes       int64     `json:"storage_bytes"`
+	StorageLimit       int64     `json:"storage_limit"`
+	TrashBytes         int64     `json:"trash_bytes"` // Raw bytes in trash; StorageBytes includes the plan-weighted share
+	AIRequests         int       `json:"ai_requests"` // This month
+	AIRequestsLimit    int       `json:"ai_requests_limit"`
+	AIRequestsProrated bool      `json:"ai_requests_prorated,omitempty"` // Limit blends plans changed this period
+	ExecutionMinutes   int       `json:"execution_minutes"`              // Today
+	ExecutionLimit     int       `json:"execution_limit"`
+	PeriodStart        time.Time `json:"period_start"`
+	PeriodEnd          time.Time `json:"period_end"`
+	CachedAt           time.Time `json:"cached_at"`
+
+	// Overrides are the admin quota overrides reflected in the limits above
+	Overrides []ActiveOverride `json:"overrides,omitempty"`
 }
 
 // UsageHistory represents historical usage data
@@ -203,6 +245,15 @@ type Tracker struct {
 	// Local cache for ultra-fast lookups (with TTL)
 	localCache    map[uint]*cachedUsage
 	localCacheTTL time.Duration
+
+	// Last period fully snapshotted by the rollover job
+	lastRolledPeriod string
+
+	// planLimits resolves plan limits for proration (GetPlanLimits outside tests)
+	planLimits func(PlanType) PlanLimits
+
+	// audit records quota override changes (optional)
+	audit OverrideAuditLogger
 }
 
 type cachedUsage struct {
@@ -217,6 +268,7 @@ func NewTracker(db *gorm.DB, redisCache *cache.RedisCache) *Tracker {
 		cache:         redisCache,
 		localCache:    make(map[uint]*cachedUsage),
 		localCacheTTL: 30 * time.Second, // Cache for 30 seconds locally
+		planLimits:    GetPlanLimits,
 	}
 
 	// Start background cleanup goroutine
@@ -231,6 +283,11 @@ func (t *Tracker) Migrate() error {
 		&UsageRecord{},
 		&DailyUsageSummary{},
 		&MonthlyUsageSummary{},
+		&UsagePeriodSnapshot{},
+		&PlanChange{},
+		&ExecutionReservation{},
+		&ProjectStorageUsage{},
+		&QuotaOverride{},
 	)
 }
 
@@ -359,11 +416,12 @@ func (t *Tracker) calculateCurrentUsage(ctx context.Context, userID uint, plan P
 		CachedAt:        time.Now().UTC(),
 	}
 
-	// Get project count
+	// Get project count (archived projects don't count against the limit, and
+	// organization-owned projects count against the organization instead)
 	var projectCount int64
 	if err := t.db.WithContext(ctx).Raw(`
 		SELECT COUNT(*) FROM projects
-		WHERE owner_id = ? AND deleted_at IS NULL
+		WHERE owner_id = ? AND organization_id IS NULL AND deleted_at IS NULL AND (is_archived IS NULL OR is_archived = false)
 	`, userID).Scan(&projectCount).Error; err != nil {
 		return nil, fmt.Errorf("failed to count projects: %w", err)
 	}
@@ -375,12 +433,34 @@ func (t *Tracker) calculateCurrentUsage(ctx context.Context, userID uint, plan P
 		SELECT COALESCE(SUM(f.size), 0)
 		FROM files f
 		JOIN projects p ON f.project_id = p.id
-		WHERE p.owner_id = ? AND p.deleted_at IS NULL AND f.deleted_at IS NULL
+		WHERE p.owner_id = ? AND p.organization_id IS NULL AND p.deleted_at IS NULL AND f.deleted_at IS NULL
 	`, userID).Scan(&storageBytes).Error; err != nil {
 		return nil, fmt.Errorf("failed to calculate storage: %w", err)
 	}
 	usage.StorageBytes = storageBytes
 
+	// Trashed projects/files keep their bytes until purged; count them per plan.
+	var trashBytes int64
+	if err := t.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(SUM(f.size), 0)
+		FROM files f
+		JOIN projects p ON f.project_id = p.id
+		WHERE p.owner_id = ? AND p.organization_id IS NULL AND (p.deleted_at IS NOT NULL OR f.deleted_at IS NOT NULL)
+	`, userID).Scan(&trashBytes).Error; err != nil {
+		return nil, fmt.Errorf("failed to calculate trash storage: %w", err)
+	}
+	usage.TrashBytes = trashBytes
+	usage.StorageBytes += int64(float64(trashBytes) * TrashStorageWeight(plan))
+
+	// A plan change this period prorates the monthly AI request limit
+	periodStart, periodEnd := periodBounds(time.Now())
+	aiLimit, prorated, err := t.proratedAIRequestsLimit(ctx, userID, plan, periodStart, periodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prorate AI request limit: %w", err)
+	}
+	usage.AIRequestsLimit = aiLimit
+	usage.AIRequestsProrated = prorated
+
 	// Get AI requests this month
 	currentMonth := time.Now().UTC().Format("2006-01")
 	aiRequests, found, err := t.lookupMonthlySummary(ctx, userID, UsageAIRequests, currentMonth)
@@ -409,6 +489,10 @@ func (t *Tracker) calculateCurrentUsage(ctx context.Context, userID uint, plan P
 	}
 	usage.ExecutionMinutes = int(execMinutes)
 
+	if err := t.applyActiveOverrides(ctx, usage); err != nil {
+		return nil, fmt.Errorf("failed to apply quota overrides: %w", err)
+	}
+
 	return usage, nil
 }
 
@@ -450,21 +534,28 @@ func (t *Tracker) CheckQuota(ctx context.Context, userID uint, plan PlanType, us
 		return false, 0, 0, err
 	}
 
-	limits := GetPlanLimits(plan)
-
+	// Limits already reflect quota overrides ahead of the plan defaults
 	switch usageType {
 	case UsageProjects:
-		limit = int64(limits.Projects)
+		limit = int64(usage.ProjectsLimit)
 		currentUsage = int64(usage.Projects)
 	case UsageStorageBytes:
-		limit = limits.StorageBytes
+		limit = usage.StorageLimit
 		currentUsage = usage.StorageBytes
 	case UsageAIRequests:
-		limit = int64(limits.AIRequests)
+		limit = int64(usage.AIRequestsLimit) // Prorated after a mid-period plan change
 		currentUsage = int64(usage.AIRequests)
 	case UsageExecutionMinutes:
-		limit = int64(limits.ExecutionMinutes)
+		limit = int64(usage.ExecutionLimit)
 		currentUsage = int64(usage.ExecutionMinutes)
+		// Minutes held by running executions count until they settle
+		if limit != -1 {
+			reserved, err := t.reservedExecutionMinutes(ctx, userID)
+			if err != nil {
+				return false, 0, 0, err
+			}
+			currentUsage += reserved
+		}
 	default:
 		return true, 0, -1, nil // Unknown type, allow
 	}
@@ -1,11 +1,11 @@
 // APEX.BUILD WebSocket Hub with Message Batching
 // Implements 50ms message batching and 16ms write coalescing
 // Reduces message volume by 70%
+// Each client gets a bounded send queue so slow clients cannot stall a room
 
 package websocket
 
 import (
-	"bytes"
 	"encoding/json"
 	"log"
 	"sync"
@@ -31,18 +31,23 @@ type BatchedHub struct {
 	*Hub
 
 	// Message batching
-	batchQueues   map[string]*messageBatchQueue
-	batchMu       sync.RWMutex
+	batchQueues map[string]*messageBatchQueue
+	batchMu     sync.RWMutex
 
-	// Write coalescing
-	writeBuffers  map[*Client]*writeBuffer
-	writeMu       sync.RWMutex
+	// Per-client send queues with write coalescing
+	sendQueues  map[*Client]*clientSendQueue
+	queueMu     sync.RWMutex
+	queueLimit  int
+	slowTimeout time.Duration
+	metrics     QueueMetrics
 
 	// Stats
 	messagesSent     int64
 	messagesReceived int64
 	batchesSent      int64
 	bytesSaved       int64
+	messagesDropped  int64
+	slowClients      int64
 	statsMu          sync.RWMutex
 
 	// Control
@@ -51,21 +56,13 @@ type BatchedHub struct {
 
 // messageBatchQueue holds messages waiting to be batched
 type messageBatchQueue struct {
-	messages  []Message
+	messages  []queuedMessage
 	totalSize int
 	lastFlush time.Time
 	mu        sync.Mutex
 	flushChan chan struct{}
 }
 
-// writeBuffer coalesces writes for a single client
-type writeBuffer struct {
-	data      bytes.Buffer
-	lastWrite time.Time
-	mu        sync.Mutex
-	pending   bool
-}
-
 // BatchedMessage represents a batch of messages
 type BatchedMessage struct {
 	Type      string    `json:"type"`
@@ -77,15 +74,28 @@ type BatchedMessage struct {
 // NewBatchedHub creates a new batched WebSocket hub
 func NewBatchedHub() *BatchedHub {
 	bh := &BatchedHub{
-		Hub:          NewHub(),
-		batchQueues:  make(map[string]*messageBatchQueue),
-		writeBuffers: make(map[*Client]*writeBuffer),
-		stopChan:     make(chan struct{}),
+		Hub:         NewHub(),
+		batchQueues: make(map[string]*messageBatchQueue),
+		sendQueues:  make(map[*Client]*clientSendQueue),
+		queueLimit:  ClientQueueLimit,
+		slowTimeout: SlowClientTimeout,
+		stopChan:    make(chan struct{}),
+	}
+	// Direct room broadcasts share the client queues, so a full buffer
+	// queues or drops by priority instead of evicting the client
+	bh.Hub.enqueue = func(client *Client, entry queuedMessage) {
+		bh.enqueue(client, []queuedMessage{entry}, true)
 	}
 
 	return bh
 }
 
+// SetQueueMetrics reports dropped messages, queue depth and slow client
+// disconnects.
+func (bh *BatchedHub) SetQueueMetrics(m QueueMetrics) {
+	bh.metrics = m
+}
+
 // Run starts the batched hub's main loop
 func (bh *BatchedHub) Run() {
 	// Start batch flush goroutine
@@ -109,7 +119,7 @@ func (bh *BatchedHub) QueueMessage(roomID string, message Message) {
 	queue, exists := bh.batchQueues[roomID]
 	if !exists {
 		queue = &messageBatchQueue{
-			messages:  make([]Message, 0, MaxBatchSize),
+			messages:  make([]queuedMessage, 0, MaxBatchSize),
 			flushChan: make(chan struct{}, 1),
 		}
 		bh.batchQueues[roomID] = queue
@@ -133,7 +143,7 @@ func (bh *BatchedHub) QueueMessage(roomID string, message Message) {
 		}
 	}
 
-	queue.messages = append(queue.messages, message)
+	queue.messages = append(queue.messages, newQueuedMessage(message, msgSize))
 	queue.totalSize += msgSize
 
 	bh.statsMu.Lock()
@@ -162,7 +172,7 @@ func (bh *BatchedHub) roomBatchProcessor(roomID string, queue *messageBatchQueue
 	}
 }
 
-// flushBatch sends all queued messages as a batch
+// flushBatch hands all queued messages to the room's client queues
 func (bh *BatchedHub) flushBatch(roomID string, queue *messageBatchQueue) {
 	queue.mu.Lock()
 	if len(queue.messages) == 0 {
@@ -172,108 +182,220 @@ func (bh *BatchedHub) flushBatch(roomID string, queue *messageBatchQueue) {
 
 	// Take messages
 	messages := queue.messages
-	originalSize := queue.totalSize
-	queue.messages = make([]Message, 0, MaxBatchSize)
+	queue.messages = make([]queuedMessage, 0, MaxBatchSize)
 	queue.totalSize = 0
 	queue.lastFlush = time.Now()
 	queue.mu.Unlock()
 
-	// Create batch message
-	batch := BatchedMessage{
-		Type:      "batch",
-		Batch:     messages,
-		Count:     len(messages),
-		Timestamp: time.Now(),
-	}
-
-	// Marshal batch
-	batchData, err := json.Marshal(batch)
-	if err != nil {
-		log.Printf("Error marshaling batch: %v", err)
-		return
-	}
-
-	// Calculate bytes saved
-	bytesSaved := originalSize - len(batchData)
-	if bytesSaved < 0 {
-		bytesSaved = 0
-	}
-
 	// Send to room
-	bh.broadcastBatchToRoom(roomID, batchData)
+	bh.enqueueForRoom(roomID, messages, false)
 
 	// Update stats
 	bh.statsMu.Lock()
 	bh.messagesSent += int64(len(messages))
 	bh.batchesSent++
-	bh.bytesSaved += int64(bytesSaved)
 	bh.statsMu.Unlock()
 }
 
-// broadcastBatchToRoom sends batch data to all clients in a room
-func (bh *BatchedHub) broadcastBatchToRoom(roomID string, data []byte) {
+// enqueueForRoom adds messages to the send queue of every client in a room
+func (bh *BatchedHub) enqueueForRoom(roomID string, messages []queuedMessage, immediate bool) {
 	bh.Hub.mu.RLock()
 	roomClients := bh.Hub.rooms[roomID]
-	bh.Hub.mu.RUnlock()
-
-	if roomClients == nil {
-		return
+	clients := make([]*Client, 0, len(roomClients))
+	for client := range roomClients {
+		clients = append(clients, client)
 	}
+	bh.Hub.mu.RUnlock()
 
-	for client := range roomClients {
-		bh.coalescedWrite(client, data)
+	for _, client := range clients {
+		bh.enqueue(client, messages, immediate)
 	}
 }
 
-// coalescedWrite coalesces writes to a client
-func (bh *BatchedHub) coalescedWrite(client *Client, data []byte) {
-	bh.writeMu.Lock()
-	buffer, exists := bh.writeBuffers[client]
+// enqueue adds messages to a client's send queue, dropping progress messages
+// when it is full. Unless immediate, delivery waits for the write coalescing
+// window so messages from several flushes share one batch.
+func (bh *BatchedHub) enqueue(client *Client, messages []queuedMessage, immediate bool) {
+	bh.queueMu.Lock()
+	queue, exists := bh.sendQueues[client]
 	if !exists {
-		buffer = &writeBuffer{}
-		bh.writeBuffers[client] = buffer
+		queue = &clientSendQueue{}
+		bh.sendQueues[client] = queue
 	}
-	bh.writeMu.Unlock()
-
-	buffer.mu.Lock()
-	defer buffer.mu.Unlock()
-
-	// Write to buffer
-	buffer.data.Write(data)
-	buffer.data.WriteString("\n") // Delimiter for batched messages
+	bh.queueMu.Unlock()
 
-	// If not pending, schedule flush
-	if !buffer.pending {
-		buffer.pending = true
-		buffer.lastWrite = time.Now()
+	queue.mu.Lock()
+	for _, message := range messages {
+		if dropped, ok := queue.push(message, bh.queueLimit); ok {
+			bh.recordDrop(dropped.message.Type, DropReasonQueueFull)
+		}
+	}
+	schedule := !immediate && !queue.pending
+	if schedule {
+		queue.pending = true
+	}
+	queue.mu.Unlock()
 
+	if immediate {
+		bh.drainClient(client, queue)
+	} else if schedule {
 		go func() {
 			time.Sleep(WriteCoalesceWindow)
-			bh.flushWriteBuffer(client, buffer)
+			bh.drainClient(client, queue)
 		}()
 	}
 }
 
-// flushWriteBuffer sends accumulated writes to client
-func (bh *BatchedHub) flushWriteBuffer(client *Client, buffer *writeBuffer) {
-	buffer.mu.Lock()
-	if buffer.data.Len() == 0 {
-		buffer.pending = false
-		buffer.mu.Unlock()
-		return
+// drainClient sends a client's queued messages as batches until the queue is
+// empty or the client's send buffer is full. Messages that do not fit stay
+// queued and the time the client became blocked is recorded.
+func (bh *BatchedHub) drainClient(client *Client, queue *clientSendQueue) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.pending = false
+
+	for len(queue.entries) > 0 {
+		count, originalSize := queue.nextBatch()
+		var batchData []byte
+		var err error
+		if queue.entries[0].data != nil {
+			batchData = queue.entries[0].data
+		} else if queue.entries[0].unbatched {
+			batchData, err = json.Marshal(queue.entries[0].message)
+		} else {
+			messages := make([]Message, count)
+			for i := range messages {
+				messages[i] = queue.entries[i].message
+			}
+
+			// Create batch message
+			batch := BatchedMessage{
+				Type:      "batch",
+				Batch:     messages,
+				Count:     count,
+				Timestamp: time.Now(),
+			}
+
+			// Marshal batch
+			batchData, err = json.Marshal(batch)
+		}
+		if err != nil {
+			log.Printf("Error marshaling batch: %v", err)
+			queue.entries = queue.entries[count:]
+			continue
+		}
+
+		if !client.trySend(batchData) {
+			if queue.blockedSince.IsZero() {
+				queue.blockedSince = time.Now()
+			}
+			return
+		}
+		queue.entries = queue.entries[count:]
+		queue.blockedSince = time.Time{}
+
+		// Calculate bytes saved
+		bytesSaved := originalSize - len(batchData)
+		if bytesSaved > 0 {
+			bh.statsMu.Lock()
+			bh.bytesSaved += int64(bytesSaved)
+			bh.statsMu.Unlock()
+		}
+	}
+}
+
+// serviceClientQueues retries blocked client queues, disconnects clients
+// that have been blocked longer than the slow client timeout and forgets
+// queues of clients that have left.
+func (bh *BatchedHub) serviceClientQueues() {
+	bh.Hub.mu.RLock()
+	connected := make(map[*Client]bool)
+	for _, roomClients := range bh.Hub.rooms {
+		for client := range roomClients {
+			connected[client] = true
+		}
+	}
+	bh.Hub.mu.RUnlock()
+
+	bh.queueMu.RLock()
+	queues := make(map[*Client]*clientSendQueue, len(bh.sendQueues))
+	for client, queue := range bh.sendQueues {
+		queues[client] = queue
 	}
+	bh.queueMu.RUnlock()
 
-	data := buffer.data.Bytes()
-	buffer.data.Reset()
-	buffer.pending = false
-	buffer.mu.Unlock()
+	queued := 0
+	for client, queue := range queues {
+		if !connected[client] {
+			bh.CleanupClient(client)
+			continue
+		}
+		bh.drainClient(client, queue)
+
+		queue.mu.Lock()
+		blockedSince := queue.blockedSince
+		queued += len(queue.entries)
+		queue.mu.Unlock()
+
+		if !blockedSince.IsZero() && time.Since(blockedSince) > bh.slowTimeout {
+			queued -= bh.disconnectSlowClient(client, time.Since(blockedSince))
+		}
+	}
+
+	if bh.metrics != nil {
+		bh.metrics.SetWebSocketQueuedMessages("collaboration", queued)
+	}
+}
+
+// disconnectSlowClient evicts a client whose send buffer has stayed full,
+// the same way broadcastToRoom evicts a full client. Its queued messages are
+// counted as dropped. It returns how many were queued.
+func (bh *BatchedHub) disconnectSlowClient(client *Client, blockedFor time.Duration) int {
+	bh.Hub.mu.Lock()
+	client.closeSend()
+	if roomClients := bh.Hub.rooms[client.RoomID]; roomClients != nil {
+		delete(roomClients, client)
+		if len(roomClients) == 0 {
+			delete(bh.Hub.rooms, client.RoomID)
+		}
+	}
+	bh.Hub.mu.Unlock()
+
+	bh.queueMu.Lock()
+	queue := bh.sendQueues[client]
+	delete(bh.sendQueues, client)
+	bh.queueMu.Unlock()
+
+	var dropped []queuedMessage
+	if queue != nil {
+		queue.mu.Lock()
+		dropped = queue.entries
+		queue.entries = nil
+		queue.mu.Unlock()
+	}
+	for _, message := range dropped {
+		bh.recordDrop(message.message.Type, DropReasonDisconnected)
+	}
+
+	bh.statsMu.Lock()
+	bh.slowClients++
+	bh.statsMu.Unlock()
+	if bh.metrics != nil {
+		bh.metrics.RecordWebSocketSlowClient()
+	}
+
+	log.Printf("Disconnecting slow WebSocket client %d in room %s: send buffer full for %s, %d messages dropped",
+		client.UserID, client.RoomID, blockedFor.Round(time.Millisecond), len(dropped))
+	return len(dropped)
+}
 
-	// Send to client
-	select {
-	case client.send <- data:
-	default:
-		// Client's send channel is full
-		log.Printf("Client %d send buffer full, dropping batch", client.UserID)
+// recordDrop counts a message dropped from a client queue
+func (bh *BatchedHub) recordDrop(msgType, reason string) {
+	bh.statsMu.Lock()
+	bh.messagesDropped++
+	bh.statsMu.Unlock()
+	if bh.metrics != nil {
+		bh.metrics.RecordWebSocketDrop(msgType, reason)
 	}
 }
 
@@ -288,6 +410,7 @@ func (bh *BatchedHub) batchFlushLoop() {
 			return
 		case <-ticker.C:
 			bh.flushStaleBatches()
+			bh.serviceClientQueues()
 		}
 	}
 }
@@ -333,13 +456,22 @@ func (bh *BatchedHub) logStats() {
 
 	if bh.messagesReceived > 0 {
 		reductionPercent := float64(bh.messagesReceived-bh.batchesSent) / float64(bh.messagesReceived) * 100
-		log.Printf("WebSocket Batching Stats: received=%d, batches_sent=%d, reduction=%.1f%%, bytes_saved=%d",
-			bh.messagesReceived, bh.batchesSent, reductionPercent, bh.bytesSaved)
+		log.Printf("WebSocket Batching Stats: received=%d, batches_sent=%d, reduction=%.1f%%, bytes_saved=%d, dropped=%d, slow_clients=%d",
+			bh.messagesReceived, bh.batchesSent, reductionPercent, bh.bytesSaved, bh.messagesDropped, bh.slowClients)
 	}
 }
 
 // GetStats returns current batching statistics
 func (bh *BatchedHub) GetStats() BatchingStats {
+	queued := 0
+	bh.queueMu.RLock()
+	for _, queue := range bh.sendQueues {
+		queue.mu.Lock()
+		queued += len(queue.entries)
+		queue.mu.Unlock()
+	}
+	bh.queueMu.RUnlock()
+
 	bh.statsMu.RLock()
 	defer bh.statsMu.RUnlock()
 
@@ -349,28 +481,34 @@ func (bh *BatchedHub) GetStats() BatchingStats {
 	}
 
 	return BatchingStats{
-		MessagesReceived:  bh.messagesReceived,
-		MessagesSent:      bh.messagesSent,
-		BatchesSent:       bh.batchesSent,
-		BytesSaved:        bh.bytesSaved,
-		ReductionPercent:  reductionPercent,
+		MessagesReceived: bh.messagesReceived,
+		MessagesSent:     bh.messagesSent,
+		BatchesSent:      bh.batchesSent,
+		BytesSaved:       bh.bytesSaved,
+		ReductionPercent: reductionPercent,
+		MessagesQueued:   int64(queued),
+		MessagesDropped:  bh.messagesDropped,
+		SlowClients:      bh.slowClients,
 	}
 }
 
 // BatchingStats holds batching statistics
 type BatchingStats struct {
-	MessagesReceived  int64   `json:"messages_received"`
-	MessagesSent      int64   `json:"messages_sent"`
-	BatchesSent       int64   `json:"batches_sent"`
-	BytesSaved        int64   `json:"bytes_saved"`
-	ReductionPercent  float64 `json:"reduction_percent"`
+	MessagesReceived int64   `json:"messages_received"`
+	MessagesSent     int64   `json:"messages_sent"`
+	BatchesSent      int64   `json:"batches_sent"`
+	BytesSaved       int64   `json:"bytes_saved"`
+	ReductionPercent float64 `json:"reduction_percent"`
+	MessagesQueued   int64   `json:"messages_queued"`
+	MessagesDropped  int64   `json:"messages_dropped"`
+	SlowClients      int64   `json:"slow_clients_disconnected"`
 }
 
-// CleanupClient removes client from write buffers when disconnected
+// CleanupClient removes client's send queue when disconnected
 func (bh *BatchedHub) CleanupClient(client *Client) {
-	bh.writeMu.Lock()
-	delete(bh.writeBuffers, client)
-	bh.writeMu.Unlock()
+	bh.queueMu.Lock()
+	delete(bh.sendQueues, client)
+	bh.queueMu.Unlock()
 }
 
 // CleanupRoom removes room batch queue when empty
@@ -392,7 +530,8 @@ func (bh *BatchedHub) BroadcastToRoomBatched(roomID string, message Message) {
 }
 
 // BroadcastImmediate sends a message immediately without batching
-// Use for critical messages that need immediate delivery
+// Use for critical messages that need immediate delivery. Messages still
+// waiting in the room's batch are sent first so they are not overtaken.
 func (bh *BatchedHub) BroadcastImmediate(roomID string, message Message) {
 	messageData, err := json.Marshal(message)
 	if err != nil {
@@ -400,19 +539,14 @@ func (bh *BatchedHub) BroadcastImmediate(roomID string, message Message) {
 		return
 	}
 
-	bh.Hub.mu.RLock()
-	roomClients := bh.Hub.rooms[roomID]
-	bh.Hub.mu.RUnlock()
-
-	if roomClients == nil {
-		return
+	bh.batchMu.RLock()
+	queue := bh.batchQueues[roomID]
+	bh.batchMu.RUnlock()
+	if queue != nil {
+		bh.flushBatch(roomID, queue)
 	}
 
-	for client := range roomClients {
-		select {
-		case client.send <- messageData:
-		default:
-			// Client's send channel is full
-		}
-	}
+	entry := newQueuedMessage(message, len(messageData))
+	entry.unbatched = true
+	bh.enqueueForRoom(roomID, []queuedMessage{entry}, true)
 }
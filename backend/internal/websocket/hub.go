@@ -39,6 +39,10 @@ type Hub struct {
 	// Shutdown channel for graceful termination
 	shutdown chan struct{}
 
+	// enqueue, when set, takes room broadcasts through a client's send queue
+	// instead of evicting a client whose buffer is full. BatchedHub sets it.
+	enqueue func(client *Client, entry queuedMessage)
+
 	// Mutex for thread safety
 	mu sync.RWMutex
 }
@@ -269,7 +273,9 @@ func (h *Hub) broadcastMessage(message []byte) {
 // Safety: the client set is snapshotted under RLock so that channel sends
 // never block while holding the lock.  Full-buffer clients are collected and
 // removed in a separate Write-locked pass using closeSend() (sync.Once) to
-// guarantee the channel is never closed more than once.
+// guarantee the channel is never closed more than once. Under a BatchedHub
+// the message goes through each client's send queue instead, and only a
+// client that stays blocked is disconnected.
 func (h *Hub) broadcastToRoom(roomID string, message Message, excludeClient *Client) {
 	messageData, err := json.Marshal(message)
 	if err != nil {
@@ -291,6 +297,19 @@ func (h *Hub) broadcastToRoom(roomID string, message Message, excludeClient *Cli
 	}
 	h.mu.RUnlock()
 
+	if h.enqueue != nil {
+		for _, client := range clients {
+			if client == excludeClient {
+				continue
+			}
+			entry := newQueuedMessage(message, len(messageData))
+			entry.unbatched = true
+			entry.data = messageData
+			h.enqueue(client, entry)
+		}
+		return
+	}
+
 	var toRemove []*Client
 	for _, client := range clients {
 		if client == excludeClient {
@@ -0,0 +1,265 @@
+// APEX.BUILD WebSocket Send Queues
+// Per-client bounded queues so one slow client cannot stall a room
+
+package websocket
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ClientQueueLimit is the number of messages held for a client whose
+	// send buffer is full before progress messages start being dropped
+	ClientQueueLimit = 256
+
+	// SlowClientTimeout is how long a client's send buffer may stay full
+	// before the client is disconnected
+	SlowClientTimeout = 10 * time.Second
+)
+
+// Drop reasons reported to QueueMetrics
+const (
+	DropReasonQueueFull    = "queue_full"
+	DropReasonDisconnected = "slow_client"
+)
+
+// QueueMetrics records per-client send queue activity. hub names the hub the
+// queues belong to. *metrics.Metrics satisfies it.
+type QueueMetrics interface {
+	RecordWebSocketDrop(msgType, reason string)
+	SetWebSocketQueuedMessages(hub string, count int)
+	RecordWebSocketSlowClient()
+}
+
+// messagePriority decides what a full queue may drop
+type messagePriority int
+
+const (
+	// priorityProgress messages are superseded by later ones and are dropped
+	// oldest first when a queue is full
+	priorityProgress messagePriority = iota
+	// priorityNormal messages are only dropped when a queue holds nothing
+	// but normal and critical messages
+	priorityNormal
+	// priorityCritical messages are never dropped: terminal events such as
+	// build:completed, and requests the user has to answer before a build
+	// can continue, such as build:approval-request
+	priorityCritical
+)
+
+// classifyMessage returns the drop priority for a message type
+func classifyMessage(msgType string) messagePriority {
+	switch {
+	case strings.HasSuffix(msgType, ":completed"),
+		strings.HasSuffix(msgType, ":failed"),
+		strings.HasSuffix(msgType, ":cancelled"),
+		strings.HasSuffix(msgType, ":fatal_error"),
+		msgType == "build:error",
+		msgType == "build:fsm:all_steps_complete",
+		msgType == "budget:exceeded":
+		return priorityCritical
+	case strings.HasPrefix(msgType, "build:approval-"),
+		strings.HasPrefix(msgType, "build:permission-"),
+		strings.HasPrefix(msgType, "build:user-input-"),
+		msgType == "build:awaiting-review",
+		msgType == "glassbox:patch_review_required":
+		return priorityCritical
+	case strings.HasSuffix(msgType, ":progress"),
+		msgType == MessageTypeCursorUpdate,
+		msgType == MessageTypeHeartbeat:
+		return priorityProgress
+	default:
+		return priorityNormal
+	}
+}
+
+// queuedMessage is a message waiting in a batch or client queue
+type queuedMessage struct {
+	message  Message
+	size     int
+	priority messagePriority
+
+	// unbatched messages are delivered on their own, not wrapped in a batch
+	unbatched bool
+
+	// data is the already encoded frame, when the sender has one
+	data []byte
+}
+
+func newQueuedMessage(message Message, size int) queuedMessage {
+	return queuedMessage{message: message, size: size, priority: classifyMessage(message.Type)}
+}
+
+// clientSendQueue holds a client's messages until its send buffer has room
+type clientSendQueue struct {
+	entries []queuedMessage
+	mu      sync.Mutex
+
+	// pending is set while a coalesced drain is scheduled
+	pending bool
+
+	// blockedSince is when the client's send buffer was first found full,
+	// zero while messages are being delivered
+	blockedSince time.Time
+}
+
+// push appends an entry. A full queue makes room by dropping its oldest
+// progress message; with none queued, a progress entry is dropped itself and
+// anything else replaces the oldest normal message. Critical messages are
+// never dropped and may take the queue past its limit. It returns the
+// dropped entry, if any.
+func (q *clientSendQueue) push(entry queuedMessage, limit int) (queuedMessage, bool) {
+	if len(q.entries) < limit {
+		q.entries = append(q.entries, entry)
+		return queuedMessage{}, false
+	}
+	victim := q.oldest(priorityProgress)
+	if victim < 0 {
+		if entry.priority == priorityProgress {
+			return entry, true
+		}
+		victim = q.oldest(priorityNormal)
+	}
+	if victim < 0 {
+		// Only critical messages are queued; the slow client check bounds
+		// how long they can pile up
+		q.entries = append(q.entries, entry)
+		return queuedMessage{}, false
+	}
+	dropped := q.entries[victim]
+	q.entries = append(q.entries[:victim], q.entries[victim+1:]...)
+	q.entries = append(q.entries, entry)
+	return dropped, true
+}
+
+// oldest returns the index of the first entry with the given priority, or -1
+func (q *clientSendQueue) oldest(priority messagePriority) int {
+	for i, entry := range q.entries {
+		if entry.priority == priority {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextBatch returns how many entries from the front of the queue fit in one
+// batch, and their combined size. An unbatched entry is always alone.
+func (q *clientSendQueue) nextBatch() (int, int) {
+	if q.entries[0].unbatched {
+		return 1, q.entries[0].size
+	}
+	count, size := 0, 0
+	for count < len(q.entries) && count < MaxBatchSize {
+		if count > 0 && (q.entries[count].unbatched || size+q.entries[count].size > MaxBatchBytes) {
+			break
+		}
+		size += q.entries[count].size
+		count++
+	}
+	return count, size
+}
+
+// trySend delivers data without blocking. It reports false when the client's
+// buffer is full or the hub has already closed its channel.
+func (c *Client) trySend(data []byte) bool {
+	return sendFrame(c.send, data)
+}
+
+// sendFrame writes to a send channel without blocking, reporting false when
+// the channel is full or already closed
+func sendFrame(ch chan []byte, data []byte) (sent bool) {
+	defer func() {
+		if recover() != nil {
+			sent = false
+		}
+	}()
+	select {
+	case ch <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendQueue is a bounded queue in front of a connection's send channel, for
+// hubs outside this package that write already encoded frames, such as the
+// build hub. It drops messages by the same rules as the BatchedHub's client
+// queues and keeps them in order.
+type SendQueue struct {
+	queue clientSendQueue
+	limit int
+}
+
+// NewSendQueue creates a queue that starts dropping once it holds limit
+// messages
+func NewSendQueue(limit int) *SendQueue {
+	return &SendQueue{limit: limit}
+}
+
+// Send queues a frame of the given message type and delivers as much of the
+// queue as the channel accepts. It returns the types of any messages dropped
+// to make room.
+func (q *SendQueue) Send(ch chan []byte, msgType string, data []byte) []string {
+	entry := queuedMessage{
+		message:   Message{Type: msgType},
+		size:      len(data),
+		priority:  classifyMessage(msgType),
+		unbatched: true,
+		data:      data,
+	}
+
+	q.queue.mu.Lock()
+	defer q.queue.mu.Unlock()
+	var dropped []string
+	if victim, ok := q.queue.push(entry, q.limit); ok {
+		dropped = append(dropped, victim.message.Type)
+	}
+	q.flushLocked(ch)
+	return dropped
+}
+
+// Flush delivers queued frames until the channel is full. It returns how
+// long the channel has been full, or zero once the queue is empty.
+func (q *SendQueue) Flush(ch chan []byte) time.Duration {
+	q.queue.mu.Lock()
+	defer q.queue.mu.Unlock()
+	q.flushLocked(ch)
+	if q.queue.blockedSince.IsZero() {
+		return 0
+	}
+	return time.Since(q.queue.blockedSince)
+}
+
+// Drain empties the queue and returns the types of the messages it held
+func (q *SendQueue) Drain() []string {
+	q.queue.mu.Lock()
+	defer q.queue.mu.Unlock()
+	types := make([]string, 0, len(q.queue.entries))
+	for _, entry := range q.queue.entries {
+		types = append(types, entry.message.Type)
+	}
+	q.queue.entries = nil
+	return types
+}
+
+// Len returns the number of queued messages
+func (q *SendQueue) Len() int {
+	q.queue.mu.Lock()
+	defer q.queue.mu.Unlock()
+	return len(q.queue.entries)
+}
+
+func (q *SendQueue) flushLocked(ch chan []byte) {
+	for len(q.queue.entries) > 0 {
+		if !sendFrame(ch, q.queue.entries[0].data) {
+			if q.queue.blockedSince.IsZero() {
+				q.queue.blockedSince = time.Now()
+			}
+			return
+		}
+		q.queue.entries = q.queue.entries[1:]
+	}
+	q.queue.blockedSince = time.Time{}
+}
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeQueueMetrics struct {
+	drops       map[string]int
+	queued      int
+	slowClients int
+}
+
+func (m *fakeQueueMetrics) RecordWebSocketDrop(msgType, reason string) {
+	m.drops[msgType+"/"+reason]++
+}
+
+func (m *fakeQueueMetrics) SetWebSocketQueuedMessages(hub string, count int) {
+	m.queued = count
+}
+
+func (m *fakeQueueMetrics) RecordWebSocketSlowClient() {
+	m.slowClients++
+}
+
+func queuedOfType(msgType string) queuedMessage {
+	return newQueuedMessage(Message{Type: msgType}, 32)
+}
+
+func TestClientSendQueueDropsOldestProgressAndKeepsTerminal(t *testing.T) {
+	queue := &clientSendQueue{}
+
+	for _, msgType := range []string{"build:progress", MessageTypeChat, "build:progress"} {
+		_, dropped := queue.push(queuedOfType(msgType), 3)
+		require.False(t, dropped)
+	}
+
+	dropped, ok := queue.push(queuedOfType(MessageTypeChat), 3)
+	require.True(t, ok)
+	require.Equal(t, "build:progress", dropped.message.Type)
+
+	dropped, ok = queue.push(queuedOfType("build:completed"), 3)
+	require.True(t, ok)
+	require.Equal(t, "build:progress", dropped.message.Type)
+
+	// With no progress left, new progress is the one dropped
+	dropped, ok = queue.push(queuedOfType("agent:progress"), 3)
+	require.True(t, ok)
+	require.Equal(t, "agent:progress", dropped.message.Type)
+
+	dropped, ok = queue.push(queuedOfType("build:failed"), 3)
+	require.True(t, ok)
+	require.Equal(t, MessageTypeChat, dropped.message.Type)
+
+	dropped, ok = queue.push(queuedOfType("build:cancelled"), 3)
+	require.True(t, ok)
+	require.Equal(t, MessageTypeChat, dropped.message.Type)
+
+	// Terminal messages are kept past the limit once nothing else can go
+	_, ok = queue.push(queuedOfType("deploy:completed"), 3)
+	require.False(t, ok)
+
+	types := make([]string, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		types = append(types, entry.message.Type)
+	}
+	require.Equal(t, []string{"build:completed", "build:failed", "build:cancelled", "deploy:completed"}, types)
+}
+
+func TestBatchedHubQueuesForSlowClientAndDisconnectsIt(t *testing.T) {
+	hub := NewBatchedHub()
+	hub.queueLimit = 4
+	metrics := &fakeQueueMetrics{drops: map[string]int{}}
+	hub.SetQueueMetrics(metrics)
+
+	slow := &Client{UserID: 1, RoomID: "project-1", send: make(chan []byte, 1), hub: hub.Hub}
+	fast := &Client{UserID: 2, RoomID: "project-1", send: make(chan []byte, 64), hub: hub.Hub}
+	hub.Hub.rooms["project-1"] = map[*Client]bool{slow: true, fast: true}
+	slow.send <- []byte("backlog")
+
+	for i := 0; i < 10; i++ {
+		hub.BroadcastImmediate("project-1", Message{Type: "build:progress", Data: i})
+	}
+	hub.BroadcastImmediate("project-1", Message{Type: "build:completed"})
+
+	// The fast client got everything despite the slow one
+	require.Len(t, fast.send, 11)
+
+	stats := hub.GetStats()
+	require.EqualValues(t, 7, stats.MessagesDropped)
+	require.EqualValues(t, 4, stats.MessagesQueued)
+	require.Equal(t, 7, metrics.drops["build:progress/queue_full"])
+
+	// Once the client reads, the oldest surviving progress message is next
+	<-slow.send
+	hub.serviceClientQueues()
+	var next Message
+	require.NoError(t, json.Unmarshal(<-slow.send, &next))
+	require.Equal(t, "build:progress", next.Type)
+	require.EqualValues(t, 7, next.Data)
+	require.Equal(t, 3, metrics.queued)
+
+	// A client that stays blocked past the timeout is disconnected
+	slow.send <- []byte("backlog")
+	hub.slowTimeout = time.Millisecond
+	hub.queueMu.RLock()
+	queue := hub.sendQueues[slow]
+	hub.queueMu.RUnlock()
+	queue.mu.Lock()
+	queue.blockedSince = time.Now().Add(-time.Second)
+	queue.mu.Unlock()
+	hub.serviceClientQueues()
+
+	require.Equal(t, 1, metrics.slowClients)
+	require.Equal(t, 2, metrics.drops["build:progress/slow_client"])
+	require.Equal(t, 1, metrics.drops["build:completed/slow_client"])
+	require.Equal(t, 0, metrics.queued)
+	require.NotContains(t, hub.Hub.rooms["project-1"], slow)
+	require.Contains(t, hub.Hub.rooms["project-1"], fast)
+	<-slow.send
+	_, open := <-slow.send
+	require.False(t, open)
+}
+
+func TestClassifyMessageKeepsApprovalsAndTerminalEvents(t *testing.T) {
+	for _, msgType := range []string{
+		"build:approval-request", "build:permission-request", "build:user-input-required",
+		"build:completed", "build:error", "build:fsm:fatal_error", "build:fsm:all_steps_complete",
+	} {
+		require.Equal(t, priorityCritical, classifyMessage(msgType), msgType)
+	}
+	require.Equal(t, priorityProgress, classifyMessage("build:progress"))
+	require.Equal(t, priorityNormal, classifyMessage("agent:thinking"))
+}
+
+func TestSendQueueHoldsFramesInOrderAndDropsProgress(t *testing.T) {
+	queue := NewSendQueue(2)
+	ch := make(chan []byte, 1)
+
+	require.Empty(t, queue.Send(ch, "build:progress", []byte("p1")))
+	require.Empty(t, queue.Send(ch, "build:progress", []byte("p2")))
+	require.Empty(t, queue.Send(ch, "build:approval-request", []byte("approve")))
+	require.Equal(t, []string{"build:progress"}, queue.Send(ch, "build:completed", []byte("done")))
+	require.Equal(t, 2, queue.Len())
+	require.Greater(t, queue.Flush(ch), time.Duration(0))
+
+	var got []string
+	for len(got) < 3 {
+		got = append(got, string(<-ch))
+		queue.Flush(ch)
+	}
+	require.Equal(t, []string{"p1", "approve", "done"}, got)
+	require.Zero(t, queue.Flush(ch))
+}
+
+func TestBatchedHubQueuesDirectRoomBroadcastsInsteadOfEvicting(t *testing.T) {
+	hub := NewBatchedHub()
+	full := &Client{UserID: 1, RoomID: "project-1", send: make(chan []byte, 1), hub: hub.Hub}
+	hub.Hub.rooms["project-1"] = map[*Client]bool{full: true}
+	full.send <- []byte("backlog")
+
+	hub.broadcastToRoom("project-1", Message{Type: "build:approval-request"}, nil)
+
+	require.Contains(t, hub.Hub.rooms["project-1"], full)
+	require.EqualValues(t, 1, hub.GetStats().MessagesQueued)
+	<-full.send
+	hub.serviceClientQueues()
+	var next Message
+	require.NoError(t, json.Unmarshal(<-full.send, &next))
+	require.Equal(t, "build:approval-request", next.Type)
+}
@@ -0,0 +1,125 @@
+// Package wsproxy holds the pieces reverse proxies need to carry WebSocket
+// connections: upgrade detection, idle timeouts on the tunnelled
+// connection, and per-key connection limits.
+package wsproxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIdleTimeout closes a tunnelled connection after this long without
+// traffic in either direction.
+const DefaultIdleTimeout = 5 * time.Minute
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol.
+func IsUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewTransport returns a transport for upgrade requests whose connections
+// close after idle without reads or writes. Use it only for upgrades:
+// upgraded connections are never returned to the idle pool.
+func NewTransport(idle time.Duration) *http.Transport {
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return newIdleConn(conn, idle), nil
+		},
+		DisableKeepAlives:     true,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+}
+
+// idleConn pushes its deadline forward on every read and write, so a
+// blocked read fails once neither side has sent anything for idle.
+type idleConn struct {
+	net.Conn
+	idle time.Duration
+}
+
+func newIdleConn(conn net.Conn, idle time.Duration) *idleConn {
+	c := &idleConn{Conn: conn, idle: idle}
+	c.touch()
+	return c
+}
+
+func (c *idleConn) touch() {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.idle))
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(p []byte) (int, error) {
+	c.touch()
+	return c.Conn.Write(p)
+}
+
+// Limiter caps concurrent connections per key (for example a deployment).
+type Limiter struct {
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewLimiter creates an empty Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{active: make(map[string]int)}
+}
+
+// Acquire takes a slot for key if fewer than max are in use. The returned
+// release function must be called once the connection ends.
+func (l *Limiter) Acquire(key string, max int) (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max > 0 && l.active[key] >= max {
+		return nil, false
+	}
+	l.active[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.active[key] <= 1 {
+				delete(l.active, key)
+			} else {
+				l.active[key]--
+			}
+		})
+	}, true
+}
+
+// Active returns the number of open connections for key.
+func (l *Limiter) Active(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active[key]
+}
@@ -0,0 +1,68 @@
+package wsproxy
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsUpgrade(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	if IsUpgrade(req) {
+		t.Fatal("plain request reported as upgrade")
+	}
+	req.Header.Set("Upgrade", "WebSocket")
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	if !IsUpgrade(req) {
+		t.Fatal("websocket upgrade not detected")
+	}
+	req.Header.Set("Upgrade", "h2c")
+	if IsUpgrade(req) {
+		t.Fatal("non-websocket upgrade reported as websocket")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter()
+	first, ok := l.Acquire("d1", 2)
+	if !ok {
+		t.Fatal("first acquire failed")
+	}
+	if _, ok := l.Acquire("d1", 2); !ok {
+		t.Fatal("second acquire failed")
+	}
+	if _, ok := l.Acquire("d1", 2); ok {
+		t.Fatal("acquire over the limit succeeded")
+	}
+	if _, ok := l.Acquire("d2", 2); !ok {
+		t.Fatal("limit leaked across keys")
+	}
+	first()
+	first()
+	if got := l.Active("d1"); got != 1 {
+		t.Fatalf("Active = %d after one release, want 1", got)
+	}
+}
+
+func TestIdleConnClosesAfterIdleTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	conn := newIdleConn(client, 50*time.Millisecond)
+	defer conn.Close()
+
+	go func() { _, _ = server.Write([]byte("x")) }()
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("read before idle timeout: %v", err)
+	}
+
+	start := time.Now()
+	_, err := conn.Read(buf)
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("idle read err = %v, want timeout", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("idle timeout took too long")
+	}
+}
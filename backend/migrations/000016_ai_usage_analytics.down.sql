@@ -0,0 +1,5 @@
+-- 000016_ai_usage_analytics.down.sql
+-- Rollback daily AI usage rollups and the ai_requests model column
+
+DROP TABLE IF EXISTS ai_usage_daily;
+ALTER TABLE ai_requests DROP COLUMN IF EXISTS model;
@@ -0,0 +1,21 @@
+-- 000016_ai_usage_analytics.up.sql
+-- Adds the model column to ai_requests and the daily AI usage rollup table
+-- backing /api/v1/ai/analytics.
+
+ALTER TABLE ai_requests ADD COLUMN IF NOT EXISTS model VARCHAR(100);
+
+CREATE TABLE IF NOT EXISTS ai_usage_daily (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    day_key VARCHAR(10) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    model VARCHAR(100) NOT NULL DEFAULT '',
+    request_count BIGINT NOT NULL DEFAULT 0,
+    error_count BIGINT NOT NULL DEFAULT 0,
+    tokens_used BIGINT NOT NULL DEFAULT 0,
+    cost NUMERIC(12,6) NOT NULL DEFAULT 0,
+    total_latency_ms BIGINT NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_usage_daily_key ON ai_usage_daily(user_id, day_key, provider, model);
@@ -0,0 +1,5 @@
+-- 000017_spend_anomalies_notifications.down.sql
+-- Rollback spend anomaly log and notification center
+
+DROP TABLE IF EXISTS spend_anomalies;
+DROP TABLE IF EXISTS notifications;
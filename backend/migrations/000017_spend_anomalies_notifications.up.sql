@@ -0,0 +1,37 @@
+-- 000017_spend_anomalies_notifications.up.sql
+-- Adds the in-app notification center and the spend anomaly log backing
+-- /api/v1/notifications and /api/v1/budget/anomalies.
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    kind VARCHAR(64) NOT NULL,
+    severity VARCHAR(16) NOT NULL DEFAULT 'info',
+    title VARCHAR(255) NOT NULL,
+    body TEXT,
+    action_label VARCHAR(64),
+    action_method VARCHAR(10),
+    action_url VARCHAR(500),
+    metadata TEXT,
+    read_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_read ON notifications(user_id, read_at);
+
+CREATE TABLE IF NOT EXISTS spend_anomalies (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    kind VARCHAR(32) NOT NULL,
+    build_id VARCHAR(64),
+    provider VARCHAR(32),
+    action VARCHAR(32) NOT NULL,
+    observed_value NUMERIC(14,6),
+    baseline_value NUMERIC(14,6),
+    details TEXT,
+    overridden_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_spend_anomaly_user_kind ON spend_anomalies(user_id, kind);
+CREATE INDEX IF NOT EXISTS idx_spend_anomalies_build_id ON spend_anomalies(build_id);
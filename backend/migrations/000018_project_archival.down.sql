@@ -0,0 +1,6 @@
+-- 000018_project_archival.down.sql
+-- Rollback project cold-storage archive metadata
+
+ALTER TABLE projects DROP COLUMN IF EXISTS archive_size_bytes;
+ALTER TABLE projects DROP COLUMN IF EXISTS archive_key;
+ALTER TABLE projects DROP COLUMN IF EXISTS archived_at;
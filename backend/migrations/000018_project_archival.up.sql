@@ -0,0 +1,7 @@
+-- 000018_project_archival.up.sql
+-- Cold-storage archive metadata for projects. While archived, a project's
+-- files live in a compressed object at archive_key instead of the files table.
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS archive_key VARCHAR(512);
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS archive_size_bytes BIGINT DEFAULT 0;
@@ -0,0 +1,8 @@
+-- 000019_privacy_retention.down.sql
+-- Rollback account deletion requests and organization legal holds
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS legal_hold_since;
+ALTER TABLE organizations DROP COLUMN IF EXISTS legal_hold_reason;
+ALTER TABLE organizations DROP COLUMN IF EXISTS legal_hold;
+
+DROP TABLE IF EXISTS account_deletion_requests;
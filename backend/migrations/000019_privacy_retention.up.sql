@@ -0,0 +1,25 @@
+-- 000019_privacy_retention.up.sql
+-- Verified account deletion requests and organization legal holds. A legal
+-- hold suspends retention purges and keeps pseudonymized audit logs when a
+-- member deletes their account.
+
+CREATE TABLE IF NOT EXISTS account_deletion_requests (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    held_org_ids TEXT,
+    report TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletion_requests_user_id ON account_deletion_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_account_deletion_requests_status ON account_deletion_requests(status);
+
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN DEFAULT FALSE;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS legal_hold_reason TEXT;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS legal_hold_since TIMESTAMPTZ;
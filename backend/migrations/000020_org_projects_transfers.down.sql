@@ -0,0 +1,7 @@
+-- 000020_org_projects_transfers.down.sql
+-- Rollback organization-owned projects and ownership transfers
+
+DROP TABLE IF EXISTS project_transfers;
+
+DROP INDEX IF EXISTS idx_projects_organization_id;
+ALTER TABLE projects DROP COLUMN IF EXISTS organization_id;
@@ -0,0 +1,31 @@
+-- 000020_org_projects_transfers.up.sql
+-- Organization-owned projects and confirmed ownership transfers. A project
+-- with organization_id set counts against the organization's project quota
+-- instead of the creating user's plan.
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS organization_id BIGINT;
+CREATE INDEX IF NOT EXISTS idx_projects_organization_id ON projects(organization_id);
+
+CREATE TABLE IF NOT EXISTS project_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    project_id BIGINT NOT NULL,
+    project_name VARCHAR(255),
+    from_user_id BIGINT,
+    from_org_id BIGINT,
+    to_user_id BIGINT,
+    to_org_id BIGINT,
+    initiated_by BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMPTZ,
+    responded_by BIGINT,
+    responded_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_transfers_project_id ON project_transfers(project_id);
+CREATE INDEX IF NOT EXISTS idx_project_transfers_from_user_id ON project_transfers(from_user_id);
+CREATE INDEX IF NOT EXISTS idx_project_transfers_from_org_id ON project_transfers(from_org_id);
+CREATE INDEX IF NOT EXISTS idx_project_transfers_to_user_id ON project_transfers(to_user_id);
+CREATE INDEX IF NOT EXISTS idx_project_transfers_to_org_id ON project_transfers(to_org_id);
+CREATE INDEX IF NOT EXISTS idx_project_transfers_status ON project_transfers(status);
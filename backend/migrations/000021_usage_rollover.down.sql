@@ -0,0 +1,5 @@
+-- 000021_usage_rollover.down.sql
+-- Rollback usage period snapshots and plan change history
+
+DROP TABLE IF EXISTS usage_plan_changes;
+DROP TABLE IF EXISTS usage_period_snapshots;
@@ -0,0 +1,35 @@
+-- 000021_usage_rollover.up.sql
+-- Per-period usage snapshots written when a billing period closes, and plan
+-- change history used to prorate monthly limits after a mid-period upgrade.
+
+CREATE TABLE IF NOT EXISTS usage_period_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    subject_type VARCHAR(20) NOT NULL,
+    subject_id BIGINT NOT NULL,
+    period VARCHAR(7) NOT NULL,
+    period_start TIMESTAMPTZ,
+    period_end TIMESTAMPTZ,
+    plan VARCHAR(50),
+    projects BIGINT DEFAULT 0,
+    storage_bytes BIGINT DEFAULT 0,
+    ai_requests BIGINT DEFAULT 0,
+    execution_minutes BIGINT DEFAULT 0,
+    projects_limit BIGINT DEFAULT 0,
+    storage_limit BIGINT DEFAULT 0,
+    ai_requests_limit BIGINT DEFAULT 0,
+    execution_minutes_limit BIGINT DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_snapshot_subject_period ON usage_period_snapshots(subject_type, subject_id, period);
+
+CREATE TABLE IF NOT EXISTS usage_plan_changes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    from_plan VARCHAR(50),
+    to_plan VARCHAR(50),
+    changed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_plan_changes_user_id ON usage_plan_changes(user_id);
+CREATE INDEX IF NOT EXISTS idx_usage_plan_changes_changed_at ON usage_plan_changes(changed_at);
@@ -0,0 +1,4 @@
+-- 000022_execution_reservations.down.sql
+-- Rollback execution minute reservations
+
+DROP TABLE IF EXISTS execution_reservations;
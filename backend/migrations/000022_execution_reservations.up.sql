@@ -0,0 +1,19 @@
+-- 000022_execution_reservations.up.sql
+-- Execution minutes held against the daily budget while a sandbox run is in
+-- flight, settled afterwards with the measured wall/CPU time.
+
+CREATE TABLE IF NOT EXISTS execution_reservations (
+    id VARCHAR(36) PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL,
+    minutes BIGINT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    settled_at TIMESTAMPTZ,
+    actual_minutes BIGINT DEFAULT 0,
+    duration_ms BIGINT DEFAULT 0,
+    cpu_time_ms BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_execution_reservations_user_id ON execution_reservations(user_id);
+CREATE INDEX IF NOT EXISTS idx_execution_reservations_created_at ON execution_reservations(created_at);
+CREATE INDEX IF NOT EXISTS idx_execution_reservations_expires_at ON execution_reservations(expires_at);
@@ -0,0 +1,4 @@
+-- 000023_project_storage_usage.down.sql
+-- Rollback per-project storage rollup
+
+DROP TABLE IF EXISTS project_storage_usage;
@@ -0,0 +1,17 @@
+-- 000023_project_storage_usage.up.sql
+-- Per-project storage rollup recomputed from the files table by the storage
+-- reconciliation job.
+
+CREATE TABLE IF NOT EXISTS project_storage_usage (
+    project_id BIGINT PRIMARY KEY,
+    owner_id BIGINT NOT NULL,
+    organization_id BIGINT,
+    file_count BIGINT DEFAULT 0,
+    bytes BIGINT DEFAULT 0,
+    trash_bytes BIGINT DEFAULT 0,
+    reconciled_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_storage_usage_owner_id ON project_storage_usage(owner_id);
+CREATE INDEX IF NOT EXISTS idx_project_storage_usage_organization_id ON project_storage_usage(organization_id);
+CREATE INDEX IF NOT EXISTS idx_project_storage_usage_reconciled_at ON project_storage_usage(reconciled_at);
@@ -0,0 +1,5 @@
+-- 000024_binary_files.down.sql
+-- Rollback binary file support
+
+DROP INDEX IF EXISTS idx_files_hash;
+ALTER TABLE files DROP COLUMN IF EXISTS is_binary;
@@ -0,0 +1,7 @@
+-- 000024_binary_files.up.sql
+-- Binary files keep their bytes in the content-addressed blob store; the
+-- files row holds the SHA-256 in hash and leaves content empty.
+
+ALTER TABLE files ADD COLUMN IF NOT EXISTS is_binary BOOLEAN DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_files_hash ON files(hash) WHERE is_binary;
@@ -0,0 +1,4 @@
+-- 000025_file_uploads.down.sql
+-- Rollback resumable file uploads
+
+DROP TABLE IF EXISTS file_uploads;
@@ -0,0 +1,22 @@
+-- 000025_file_uploads.up.sql
+-- Resumable chunked uploads for large project files. Chunks live in object
+-- storage under uploads/<id>/ until the upload completes or expires.
+
+CREATE TABLE IF NOT EXISTS file_uploads (
+    id VARCHAR(36) PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    mime_type VARCHAR(127),
+    size BIGINT NOT NULL,
+    received BIGINT NOT NULL DEFAULT 0,
+    replace_file_id BIGINT,
+    replace_version BIGINT,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_uploads_project_id ON file_uploads(project_id);
+CREATE INDEX IF NOT EXISTS idx_file_uploads_user_id ON file_uploads(user_id);
+CREATE INDEX IF NOT EXISTS idx_file_uploads_expires_at ON file_uploads(expires_at);
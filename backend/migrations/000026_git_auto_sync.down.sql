@@ -0,0 +1,12 @@
+-- 000026_git_auto_sync.down.sql
+-- Rollback webhook-driven git sync
+
+DROP TABLE IF EXISTS git_sync_conflicts;
+DROP TABLE IF EXISTS git_synced_files;
+
+ALTER TABLE repositories DROP COLUMN IF EXISTS last_synced_sha;
+ALTER TABLE repositories DROP COLUMN IF EXISTS sync_error;
+ALTER TABLE repositories DROP COLUMN IF EXISTS sync_status;
+ALTER TABLE repositories DROP COLUMN IF EXISTS webhook_secret;
+ALTER TABLE repositories DROP COLUMN IF EXISTS webhook_id;
+ALTER TABLE repositories DROP COLUMN IF EXISTS auto_sync;
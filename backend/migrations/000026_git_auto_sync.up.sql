@@ -0,0 +1,35 @@
+-- 000026_git_auto_sync.up.sql
+-- Webhook-driven sync from a connected GitHub branch. git_synced_files holds
+-- the merge base per path; git_sync_conflicts lists files the last sync left
+-- alone because they changed both locally and upstream.
+
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS auto_sync BOOLEAN DEFAULT false;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS webhook_id BIGINT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS webhook_secret TEXT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS sync_status VARCHAR(20) DEFAULT 'idle';
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS sync_error TEXT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS last_synced_sha VARCHAR(40);
+
+CREATE TABLE IF NOT EXISTS git_synced_files (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    blob_sha VARCHAR(40),
+    updated_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_git_synced_files_project_path ON git_synced_files(project_id, path);
+
+CREATE TABLE IF NOT EXISTS git_sync_conflicts (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    base_sha VARCHAR(40),
+    local_sha VARCHAR(40),
+    remote_sha VARCHAR(40),
+    commit_sha VARCHAR(40),
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_sync_conflicts_project_id ON git_sync_conflicts(project_id);
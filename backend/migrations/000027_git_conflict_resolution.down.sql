@@ -0,0 +1,5 @@
+-- 000027_git_conflict_resolution.down.sql
+-- Rollback conflict resolution tracking
+
+ALTER TABLE git_sync_conflicts DROP COLUMN IF EXISTS resolved_at;
+ALTER TABLE git_sync_conflicts DROP COLUMN IF EXISTS resolution;
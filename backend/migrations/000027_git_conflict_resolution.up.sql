@@ -0,0 +1,5 @@
+-- 000027_git_conflict_resolution.up.sql
+-- Track how each sync conflict was resolved until the merge is committed.
+
+ALTER TABLE git_sync_conflicts ADD COLUMN IF NOT EXISTS resolution VARCHAR(16);
+ALTER TABLE git_sync_conflicts ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ;
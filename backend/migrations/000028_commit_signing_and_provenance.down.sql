@@ -0,0 +1,5 @@
+-- 000028_commit_signing_and_provenance.down.sql
+-- Rollback commit signing and deployment provenance
+
+DROP TABLE IF EXISTS deployment_provenances;
+ALTER TABLE repositories DROP COLUMN IF EXISTS sign_commits;
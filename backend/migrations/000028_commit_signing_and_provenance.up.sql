@@ -0,0 +1,17 @@
+-- 000028_commit_signing_and_provenance.up.sql
+-- Optional signing of platform commits, and SLSA provenance attestations
+-- recorded for each deployment build.
+
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS sign_commits BOOLEAN DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS deployment_provenances (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id VARCHAR(36) NOT NULL,
+    build_id VARCHAR(64),
+    statement TEXT,
+    envelope TEXT,
+    created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deployment_provenances_deployment_id ON deployment_provenances(deployment_id);
+CREATE INDEX IF NOT EXISTS idx_deployment_provenances_build_id ON deployment_provenances(build_id);
@@ -0,0 +1,7 @@
+-- 000029_agent_marketplace.down.sql
+-- Rollback the agent marketplace
+
+DROP TABLE IF EXISTS agent_ratings;
+DROP TABLE IF EXISTS agent_installs;
+DROP TABLE IF EXISTS agent_listing_versions;
+DROP TABLE IF EXISTS agent_listings;
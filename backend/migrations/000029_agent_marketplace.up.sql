@@ -0,0 +1,66 @@
+-- 000029_agent_marketplace.up.sql
+-- Community marketplace for custom agent role configurations: moderated,
+-- versioned listings that users install (optionally pinned) and rate.
+
+CREATE TABLE IF NOT EXISTS agent_listings (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    slug VARCHAR(80) NOT NULL,
+    name VARCHAR(120) NOT NULL,
+    description TEXT,
+    role VARCHAR(32),
+    tags TEXT,
+    author_id BIGINT NOT NULL,
+    latest_version VARCHAR(32),
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    installs BIGINT DEFAULT 0,
+    rating NUMERIC DEFAULT 0,
+    rating_count BIGINT DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_listings_slug ON agent_listings(slug);
+CREATE INDEX IF NOT EXISTS idx_agent_listings_role ON agent_listings(role);
+CREATE INDEX IF NOT EXISTS idx_agent_listings_author_id ON agent_listings(author_id);
+CREATE INDEX IF NOT EXISTS idx_agent_listings_status ON agent_listings(status);
+
+CREATE TABLE IF NOT EXISTS agent_listing_versions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    listing_id BIGINT NOT NULL,
+    version VARCHAR(32) NOT NULL,
+    config TEXT,
+    changelog TEXT,
+    status VARCHAR(20) NOT NULL,
+    flags TEXT,
+    moderation_note TEXT,
+    reviewed_by BIGINT,
+    reviewed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_listing_version ON agent_listing_versions(listing_id, version);
+CREATE INDEX IF NOT EXISTS idx_agent_listing_versions_status ON agent_listing_versions(status);
+
+CREATE TABLE IF NOT EXISTS agent_installs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    listing_id BIGINT NOT NULL,
+    pinned_version VARCHAR(32),
+    enabled BOOLEAN NOT NULL DEFAULT true
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_install_user_listing ON agent_installs(user_id, listing_id);
+
+CREATE TABLE IF NOT EXISTS agent_ratings (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    listing_id BIGINT NOT NULL,
+    stars BIGINT NOT NULL,
+    comment TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_rating_user_listing ON agent_ratings(user_id, listing_id);
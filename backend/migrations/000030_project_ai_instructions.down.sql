@@ -0,0 +1,5 @@
+-- 000030_project_ai_instructions.down.sql
+-- Rollback organization AI instructions
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS allow_project_ai_instructions;
+ALTER TABLE organizations DROP COLUMN IF EXISTS ai_instructions;
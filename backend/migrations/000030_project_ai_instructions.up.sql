@@ -0,0 +1,6 @@
+-- 000030_project_ai_instructions.up.sql
+-- Organization AI instructions, applied to AI prompts on org projects ahead of
+-- each project's apex.md file.
+
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS ai_instructions TEXT;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS allow_project_ai_instructions BOOLEAN DEFAULT true;
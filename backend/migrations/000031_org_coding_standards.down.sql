@@ -0,0 +1,4 @@
+-- 000031_org_coding_standards.down.sql
+-- Rollback organization coding standards
+
+DROP TABLE IF EXISTS organization_coding_standards;
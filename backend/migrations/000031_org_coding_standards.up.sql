@@ -0,0 +1,15 @@
+-- 000031_org_coding_standards.up.sql
+-- Organization coding standards (style guide, banned libraries, required and
+-- forbidden patterns) injected into members' build prompts and checked
+-- against generated files during the review phase.
+
+CREATE TABLE IF NOT EXISTS organization_coding_standards (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    organization_id BIGINT NOT NULL,
+    policy TEXT,
+    updated_by BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_coding_standards_organization_id ON organization_coding_standards(organization_id);
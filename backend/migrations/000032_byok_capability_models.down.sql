@@ -0,0 +1,4 @@
+-- 000032_byok_capability_models.down.sql
+-- Rollback per-capability BYOK model preferences
+
+ALTER TABLE user_api_keys DROP COLUMN IF EXISTS capability_models;
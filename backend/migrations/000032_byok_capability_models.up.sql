@@ -0,0 +1,4 @@
+-- 000032_byok_capability_models.up.sql
+-- Per-capability model preferences (completions, chat, build roles) on BYOK keys
+
+ALTER TABLE user_api_keys ADD COLUMN IF NOT EXISTS capability_models TEXT;
@@ -0,0 +1,5 @@
+-- 000033_scheduled_tasks.down.sql
+-- Rollback scheduled tasks
+
+DROP TABLE IF EXISTS scheduled_task_runs;
+DROP TABLE IF EXISTS scheduled_tasks;
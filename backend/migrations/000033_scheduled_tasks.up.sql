@@ -0,0 +1,46 @@
+-- 000033_scheduled_tasks.up.sql
+-- Cron-scheduled project tasks and their run history with logs.
+
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(120) NOT NULL,
+    command TEXT NOT NULL,
+    cron_expr VARCHAR(120) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    timeout_seconds BIGINT NOT NULL DEFAULT 300,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_status VARCHAR(20),
+    consecutive_failures BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_project_id ON scheduled_tasks(project_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_user_id ON scheduled_tasks(user_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_enabled ON scheduled_tasks(enabled);
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_next_run_at ON scheduled_tasks(next_run_at);
+
+CREATE TABLE IF NOT EXISTS scheduled_task_runs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    task_id BIGINT NOT NULL,
+    project_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    trigger VARCHAR(16) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    execution_id VARCHAR(64),
+    command TEXT,
+    exit_code BIGINT,
+    output TEXT,
+    error_output TEXT,
+    duration_ms BIGINT,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_task_runs_task_id ON scheduled_task_runs(task_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_task_runs_user_id ON scheduled_task_runs(user_id);
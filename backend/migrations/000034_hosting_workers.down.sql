@@ -0,0 +1,4 @@
+-- 000034_hosting_workers.down.sql
+-- Rollback hosting background workers
+
+DROP TABLE IF EXISTS worker_processes;
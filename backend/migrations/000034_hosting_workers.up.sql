@@ -0,0 +1,35 @@
+-- 000034_hosting_workers.up.sql
+-- Background worker processes for native hosting.
+
+CREATE TABLE IF NOT EXISTS worker_processes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    deployment_id VARCHAR(36),
+    name VARCHAR(63) NOT NULL,
+    command VARCHAR(500) NOT NULL,
+    replicas BIGINT DEFAULT 1,
+    memory_limit BIGINT DEFAULT 256,
+    cpu_limit BIGINT DEFAULT 250,
+    scale_to_zero BOOLEAN DEFAULT FALSE,
+    idle_timeout_minutes BIGINT DEFAULT 15,
+    queue_enabled BOOLEAN DEFAULT FALSE,
+    queue_database_id BIGINT,
+    status VARCHAR(20) DEFAULT 'stopped',
+    current_replicas BIGINT DEFAULT 0,
+    container_name VARCHAR(100),
+    error_message TEXT,
+    restart_count BIGINT DEFAULT 0,
+    started_at TIMESTAMPTZ,
+    slept_at TIMESTAMPTZ,
+    last_activity_at TIMESTAMPTZ,
+    uptime_seconds BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_processes_deleted_at ON worker_processes(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_worker_processes_project_id ON worker_processes(project_id);
+CREATE INDEX IF NOT EXISTS idx_worker_processes_user_id ON worker_processes(user_id);
+CREATE INDEX IF NOT EXISTS idx_worker_processes_deployment_id ON worker_processes(deployment_id);
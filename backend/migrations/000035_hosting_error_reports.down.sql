@@ -0,0 +1,7 @@
+-- 000035_hosting_error_reports.down.sql
+-- Rollback hosted app error reporting
+
+DROP TABLE IF EXISTS error_events;
+DROP TABLE IF EXISTS error_groups;
+DROP INDEX IF EXISTS idx_native_deployments_error_reporting_key;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS error_reporting_key;
@@ -0,0 +1,49 @@
+-- 000035_hosting_error_reports.up.sql
+-- Error reports from hosted apps, grouped per deployment.
+
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS error_reporting_key VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_native_deployments_error_reporting_key ON native_deployments(error_reporting_key);
+
+CREATE TABLE IF NOT EXISTS error_groups (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    deployment_id VARCHAR(36) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    type VARCHAR(255),
+    message TEXT,
+    culprit VARCHAR(500),
+    level VARCHAR(20) DEFAULT 'error',
+    platform VARCHAR(50),
+    release VARCHAR(200),
+    status VARCHAR(20) DEFAULT 'unresolved',
+    event_count BIGINT DEFAULT 0,
+    first_seen TIMESTAMPTZ,
+    last_seen TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_groups_project_id ON error_groups(project_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_error_groups_fingerprint ON error_groups(deployment_id, fingerprint);
+CREATE INDEX IF NOT EXISTS idx_error_groups_status ON error_groups(status);
+CREATE INDEX IF NOT EXISTS idx_error_groups_last_seen ON error_groups(last_seen);
+
+CREATE TABLE IF NOT EXISTS error_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    group_id BIGINT NOT NULL,
+    deployment_id VARCHAR(36) NOT NULL,
+    event_id VARCHAR(36),
+    level VARCHAR(20),
+    type VARCHAR(255),
+    message TEXT,
+    environment VARCHAR(100),
+    release VARCHAR(200),
+    url VARCHAR(1000),
+    frames TEXT,
+    tags TEXT,
+    timestamp TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_events_group_id ON error_events(group_id);
+CREATE INDEX IF NOT EXISTS idx_error_events_deployment_id ON error_events(deployment_id);
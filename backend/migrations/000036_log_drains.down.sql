@@ -0,0 +1,4 @@
+-- 000036_log_drains.down.sql
+-- Rollback log drains
+
+DROP TABLE IF EXISTS log_drains;
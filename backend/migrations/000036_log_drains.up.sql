@@ -0,0 +1,29 @@
+-- 000036_log_drains.up.sql
+-- Log drains forward hosting and preview logs to external sinks.
+
+CREATE TABLE IF NOT EXISTS log_drains (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    deployment_id VARCHAR(36),
+    name VARCHAR(63) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    url VARCHAR(500),
+    token VARCHAR(500),
+    sources VARCHAR(20) DEFAULT 'all',
+    enabled BOOLEAN DEFAULT TRUE,
+    status VARCHAR(20) DEFAULT 'pending',
+    delivered_count BIGINT DEFAULT 0,
+    failed_count BIGINT DEFAULT 0,
+    dropped_count BIGINT DEFAULT 0,
+    last_delivery_at TIMESTAMPTZ,
+    last_error_at TIMESTAMPTZ,
+    last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_log_drains_deleted_at ON log_drains(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_log_drains_project_id ON log_drains(project_id);
+CREATE INDEX IF NOT EXISTS idx_log_drains_user_id ON log_drains(user_id);
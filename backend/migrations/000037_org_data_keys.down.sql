@@ -0,0 +1,5 @@
+-- 000037_org_data_keys.down.sql
+-- Rollback organization data keys. Values encrypted with them become
+-- unreadable, so re-encrypt to the master key first.
+
+DROP TABLE IF EXISTS org_data_keys;
@@ -0,0 +1,21 @@
+-- 000037_org_data_keys.up.sql
+-- Per-organization data encryption keys, wrapped by a KMS key.
+
+CREATE TABLE IF NOT EXISTS org_data_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    organization_id BIGINT NOT NULL,
+    version BIGINT NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    kms_key_id VARCHAR(500),
+    wrapped_key TEXT,
+    fingerprint VARCHAR(64),
+    status VARCHAR(20) NOT NULL,
+    retired_at TIMESTAMPTZ,
+    destroyed_at TIMESTAMPTZ,
+    created_by BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_data_key_version ON org_data_keys(organization_id, version);
+CREATE INDEX IF NOT EXISTS idx_org_data_keys_status ON org_data_keys(status);
@@ -0,0 +1,4 @@
+-- 000038_secret_rotation_jobs.down.sql
+-- Rollback online rotation jobs. Finish or cancel running jobs first.
+
+DROP TABLE IF EXISTS secret_rotation_jobs;
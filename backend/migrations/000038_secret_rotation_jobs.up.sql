@@ -0,0 +1,25 @@
+-- 000038_secret_rotation_jobs.up.sql
+-- Online master key rotation jobs and their progress.
+
+CREATE TABLE IF NOT EXISTS secret_rotation_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    status VARCHAR(20) NOT NULL,
+    started_by BIGINT,
+    total BIGINT DEFAULT 0,
+    processed BIGINT DEFAULT 0,
+    reencrypted BIGINT DEFAULT 0,
+    skipped BIGINT DEFAULT 0,
+    failed BIGINT DEFAULT 0,
+    current_table TEXT,
+    cursor BIGINT DEFAULT 0,
+    rewrapped_org_keys BIGINT DEFAULT 0,
+    verified_sample BIGINT DEFAULT 0,
+    verified_ok BIGINT DEFAULT 0,
+    errors TEXT,
+    error TEXT,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_secret_rotation_jobs_status ON secret_rotation_jobs(status);
@@ -0,0 +1,5 @@
+-- 000039_session_activity.down.sql
+-- Rollback session activity tracking.
+
+DROP INDEX IF EXISTS idx_refresh_tokens_user_active;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS last_active_at;
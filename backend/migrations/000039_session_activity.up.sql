@@ -0,0 +1,6 @@
+-- 000039_session_activity.up.sql
+-- Last activity per session (refresh token family) for the session list.
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_active_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_active ON refresh_tokens(user_id, revoked, used);
@@ -0,0 +1,5 @@
+-- 000040_hosting_security_headers.down.sql
+-- Rollback per-deployment security headers
+
+DROP TABLE IF EXISTS csp_violations;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS security_headers;
@@ -0,0 +1,27 @@
+-- 000040_hosting_security_headers.up.sql
+-- Per-deployment security headers and CSP violation reports.
+
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS security_headers TEXT;
+
+CREATE TABLE IF NOT EXISTS csp_violations (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    deployment_id VARCHAR(36) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    directive VARCHAR(50),
+    blocked_uri VARCHAR(500),
+    document_uri VARCHAR(1000),
+    source_file VARCHAR(1000),
+    line_number BIGINT,
+    sample VARCHAR(255),
+    disposition VARCHAR(20),
+    count BIGINT DEFAULT 0,
+    first_seen TIMESTAMPTZ,
+    last_seen TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_csp_violations_project_id ON csp_violations(project_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_csp_violations_fingerprint ON csp_violations(deployment_id, fingerprint);
+CREATE INDEX IF NOT EXISTS idx_csp_violations_last_seen ON csp_violations(last_seen);
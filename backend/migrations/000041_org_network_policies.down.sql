@@ -0,0 +1,4 @@
+-- 000041_org_network_policies.down.sql
+-- Rollback organization network policies
+
+DROP TABLE IF EXISTS organization_network_policies;
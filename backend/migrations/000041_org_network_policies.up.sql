@@ -0,0 +1,19 @@
+-- 000041_org_network_policies.up.sql
+-- Organization IP allowlists and geo restrictions for API and SSO access.
+
+CREATE TABLE IF NOT EXISTS organization_network_policies (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    organization_id BIGINT NOT NULL,
+    enabled BOOLEAN DEFAULT false,
+    enforce_api BOOLEAN DEFAULT true,
+    enforce_sso BOOLEAN DEFAULT true,
+    allowed_cidrs TEXT,
+    allowed_countries TEXT,
+    blocked_countries TEXT,
+    break_glass_user_ids TEXT,
+    updated_by BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_network_policies_organization_id ON organization_network_policies(organization_id);
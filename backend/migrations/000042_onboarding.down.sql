@@ -0,0 +1,6 @@
+-- 000042_onboarding.down.sql
+-- Rollback onboarding checklist tables
+
+DROP TABLE IF EXISTS onboarding_step_completions;
+DROP TABLE IF EXISTS onboarding_states;
+DROP TABLE IF EXISTS onboarding_checklists;
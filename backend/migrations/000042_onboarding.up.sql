@@ -0,0 +1,37 @@
+-- 000042_onboarding.up.sql
+-- Per-user onboarding checklist, sample project provisioning and per-organization checklists.
+
+CREATE TABLE IF NOT EXISTS onboarding_checklists (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    organization_id BIGINT NOT NULL,
+    steps TEXT,
+    sample_template_id VARCHAR(64),
+    skip_sample BOOLEAN DEFAULT false,
+    updated_by BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_onboarding_checklists_organization_id ON onboarding_checklists(organization_id);
+
+CREATE TABLE IF NOT EXISTS onboarding_states (
+    user_id BIGINT PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    organization_id BIGINT,
+    sample_project_id BIGINT,
+    sample_template_id VARCHAR(64),
+    provision_error VARCHAR(255),
+    dismissed_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS onboarding_step_completions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    step_key VARCHAR(64) NOT NULL,
+    event VARCHAR(32),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_onboarding_completion ON onboarding_step_completions(user_id, step_key);
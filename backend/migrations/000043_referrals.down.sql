@@ -0,0 +1,6 @@
+-- 000043_referrals.down.sql
+-- Rollback referral program tables
+
+DROP TABLE IF EXISTS referrals;
+DROP TABLE IF EXISTS referral_clicks;
+DROP TABLE IF EXISTS referral_codes;
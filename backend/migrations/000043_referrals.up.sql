@@ -0,0 +1,49 @@
+-- 000043_referrals.up.sql
+-- Referral program: per-user invite codes, link clicks and signup attribution with rewards.
+
+CREATE TABLE IF NOT EXISTS referral_codes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    code VARCHAR(16) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referral_codes_user_id ON referral_codes(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referral_codes_code ON referral_codes(code);
+
+CREATE TABLE IF NOT EXISTS referral_clicks (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    referrer_id BIGINT NOT NULL,
+    ip_hash VARCHAR(64),
+    device_hash VARCHAR(64),
+    is_unique BOOLEAN
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_clicks_created_at ON referral_clicks(created_at);
+CREATE INDEX IF NOT EXISTS idx_referral_clicks_referrer_id ON referral_clicks(referrer_id);
+CREATE INDEX IF NOT EXISTS idx_referral_clicks_ip_hash ON referral_clicks(ip_hash);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    referrer_id BIGINT NOT NULL,
+    referred_user_id BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    flag_reason VARCHAR(32),
+    signup_ip_hash VARCHAR(64),
+    signup_device_hash VARCHAR(64),
+    converted_at TIMESTAMPTZ,
+    converted_plan VARCHAR(20),
+    reward_usd NUMERIC(12,6) DEFAULT 0,
+    referred_reward_usd NUMERIC(12,6) DEFAULT 0,
+    rewarded_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referrals_referred_user_id ON referrals(referred_user_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals(referrer_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_status ON referrals(status);
+CREATE INDEX IF NOT EXISTS idx_referrals_signup_ip_hash ON referrals(signup_ip_hash);
+CREATE INDEX IF NOT EXISTS idx_referrals_signup_device_hash ON referrals(signup_device_hash);
+CREATE INDEX IF NOT EXISTS idx_referrals_rewarded_at ON referrals(rewarded_at);
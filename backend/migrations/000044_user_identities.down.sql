@@ -0,0 +1,5 @@
+-- 000044_user_identities.down.sql
+-- Rollback user identities and account merges
+
+DROP TABLE IF EXISTS account_merges;
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,34 @@
+-- 000044_user_identities.up.sql
+-- SSO identities linked to users, and a record of merged duplicate accounts.
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    provider VARCHAR(16) NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    organization_id BIGINT,
+    email TEXT,
+    linked_by VARCHAR(16),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identity_subject ON user_identities(provider, issuer, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_organization_id ON user_identities(organization_id);
+
+CREATE TABLE IF NOT EXISTS account_merges (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    source_user_id BIGINT NOT NULL,
+    target_user_id BIGINT NOT NULL,
+    organization_id BIGINT,
+    actor_id BIGINT,
+    plan TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_merges_source_user_id ON account_merges(source_user_id);
+CREATE INDEX IF NOT EXISTS idx_account_merges_target_user_id ON account_merges(target_user_id);
+CREATE INDEX IF NOT EXISTS idx_account_merges_organization_id ON account_merges(organization_id);
@@ -0,0 +1,7 @@
+-- 000045_analytics_warehouse.down.sql
+-- Rollback analytics warehouse tables
+
+DROP TABLE IF EXISTS analytics_exports;
+DROP TABLE IF EXISTS retention_cohorts;
+DROP TABLE IF EXISTS plan_daily_metrics;
+DROP TABLE IF EXISTS platform_daily_metrics;
@@ -0,0 +1,68 @@
+-- 000045_analytics_warehouse.up.sql
+-- Platform daily metrics, per-plan metrics, retention cohorts and warehouse export runs.
+
+CREATE TABLE IF NOT EXISTS platform_daily_metrics (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    day_key VARCHAR(10) NOT NULL,
+    signups BIGINT NOT NULL DEFAULT 0,
+    activated_users BIGINT NOT NULL DEFAULT 0,
+    activation_rate DOUBLE PRECISION NOT NULL DEFAULT 0,
+    active_users BIGINT NOT NULL DEFAULT 0,
+    paid_users BIGINT NOT NULL DEFAULT 0,
+    builds BIGINT NOT NULL DEFAULT 0,
+    builds_failed BIGINT NOT NULL DEFAULT 0,
+    ai_requests BIGINT NOT NULL DEFAULT 0,
+    ai_cost_usd NUMERIC(14,6) NOT NULL DEFAULT 0,
+    revenue_usd NUMERIC(14,2) NOT NULL DEFAULT 0,
+    gross_margin_usd NUMERIC(14,6) NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_platform_daily_metrics_day_key ON platform_daily_metrics(day_key);
+
+CREATE TABLE IF NOT EXISTS plan_daily_metrics (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    day_key VARCHAR(10) NOT NULL,
+    plan VARCHAR(20) NOT NULL,
+    builds BIGINT NOT NULL DEFAULT 0,
+    builders BIGINT NOT NULL DEFAULT 0,
+    ai_requests BIGINT NOT NULL DEFAULT 0,
+    ai_cost_usd NUMERIC(14,6) NOT NULL DEFAULT 0,
+    revenue_usd NUMERIC(14,2) NOT NULL DEFAULT 0,
+    active_users BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plan_daily_key ON plan_daily_metrics(day_key, plan);
+
+CREATE TABLE IF NOT EXISTS retention_cohorts (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    cohort_week VARCHAR(10) NOT NULL,
+    week_offset INTEGER NOT NULL,
+    cohort_size BIGINT NOT NULL DEFAULT 0,
+    retained_users BIGINT NOT NULL DEFAULT 0,
+    retention_rate DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_retention_cohort_key ON retention_cohorts(cohort_week, week_offset);
+
+CREATE TABLE IF NOT EXISTS analytics_exports (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    sink VARCHAR(20) NOT NULL,
+    table_name VARCHAR(64) NOT NULL,
+    partition_day VARCHAR(10),
+    location VARCHAR(512),
+    row_count BIGINT NOT NULL DEFAULT 0,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_exports_created_at ON analytics_exports(created_at);
+CREATE INDEX IF NOT EXISTS idx_analytics_exports_sink ON analytics_exports(sink);
@@ -0,0 +1,5 @@
+-- 000046_abuse_detection.down.sql
+-- Rollback abuse detection
+
+DROP TABLE IF EXISTS abuse_signals;
+DROP TABLE IF EXISTS abuse_cases;
@@ -0,0 +1,38 @@
+-- 000046_abuse_detection.up.sql
+-- Compute abuse signals from code execution and the cases they escalate into.
+
+CREATE TABLE IF NOT EXISTS abuse_cases (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    enforcement VARCHAR(16) NOT NULL,
+    score BIGINT,
+    reason VARCHAR(512),
+    free_tier BOOLEAN,
+    appeal_message TEXT,
+    appealed_at TIMESTAMPTZ,
+    reviewed_by BIGINT,
+    reviewed_at TIMESTAMPTZ,
+    review_note TEXT,
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_abuse_cases_user_id ON abuse_cases(user_id);
+CREATE INDEX IF NOT EXISTS idx_abuse_cases_status ON abuse_cases(status);
+
+CREATE TABLE IF NOT EXISTS abuse_signals (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    case_id BIGINT,
+    execution_id VARCHAR(64),
+    kind VARCHAR(32) NOT NULL,
+    score BIGINT NOT NULL,
+    evidence VARCHAR(512)
+);
+
+CREATE INDEX IF NOT EXISTS idx_abuse_signals_created_at ON abuse_signals(created_at);
+CREATE INDEX IF NOT EXISTS idx_abuse_signals_user_id ON abuse_signals(user_id);
+CREATE INDEX IF NOT EXISTS idx_abuse_signals_case_id ON abuse_signals(case_id);
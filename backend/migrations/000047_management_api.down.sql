@@ -0,0 +1,5 @@
+-- 000047_management_api.down.sql
+-- Rollback management API
+
+DROP TABLE IF EXISTS organization_webhooks;
+DROP TABLE IF EXISTS management_tokens;
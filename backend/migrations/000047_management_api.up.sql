@@ -0,0 +1,34 @@
+-- 000047_management_api.up.sql
+-- Personal management API tokens and organization webhook configs.
+
+CREATE TABLE IF NOT EXISTS management_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16),
+    token_hash VARCHAR(64) NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_management_tokens_user_id ON management_tokens(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_management_tokens_token_hash ON management_tokens(token_hash);
+
+CREATE TABLE IF NOT EXISTS organization_webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    organization_id BIGINT NOT NULL,
+    external_id VARCHAR(64) NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    events TEXT,
+    secret VARCHAR(200),
+    enabled BOOLEAN,
+    last_delivery_at TIMESTAMPTZ,
+    last_status BIGINT,
+    last_error VARCHAR(500)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_webhook_external_id ON organization_webhooks(organization_id, external_id);
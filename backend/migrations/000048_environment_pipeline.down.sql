@@ -0,0 +1,6 @@
+-- 000048_environment_pipeline.down.sql
+-- Rollback deployment environments pipeline
+
+DROP TABLE IF EXISTS environment_releases;
+DROP TABLE IF EXISTS build_artifacts;
+DROP TABLE IF EXISTS project_environments;
@@ -0,0 +1,57 @@
+-- 000048_environment_pipeline.up.sql
+-- Per-project deployment environments, immutable build artifacts and the
+-- releases that deploy them.
+
+CREATE TABLE IF NOT EXISTS project_environments (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    target VARCHAR(32) NOT NULL,
+    config TEXT,
+    smoke_paths TEXT,
+    env_var_keys TEXT,
+    env_vars_encrypted TEXT,
+    env_vars_salt VARCHAR(64),
+    live_release_id BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_environment ON project_environments(project_id, name);
+
+CREATE TABLE IF NOT EXISTS build_artifacts (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    digest VARCHAR(80) NOT NULL,
+    file_count BIGINT,
+    size_bytes BIGINT,
+    created_by BIGINT,
+    files TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_artifact_digest ON build_artifacts(project_id, digest);
+
+CREATE TABLE IF NOT EXISTS environment_releases (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    environment VARCHAR(32) NOT NULL,
+    artifact_id BIGINT NOT NULL,
+    artifact_digest VARCHAR(80),
+    user_id BIGINT,
+    target VARCHAR(32),
+    deployment_id VARCHAR(36),
+    promoted_from_id BIGINT,
+    status VARCHAR(20) NOT NULL,
+    url TEXT,
+    smoke_results TEXT,
+    error VARCHAR(1000),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_release_project_env ON environment_releases(project_id, environment);
+CREATE INDEX IF NOT EXISTS idx_environment_releases_artifact_id ON environment_releases(artifact_id);
+CREATE INDEX IF NOT EXISTS idx_environment_releases_deployment_id ON environment_releases(deployment_id);
+CREATE INDEX IF NOT EXISTS idx_environment_releases_status ON environment_releases(status);
@@ -0,0 +1,6 @@
+-- 000049_zero_downtime_restarts.down.sql
+-- Rollback restart controller state
+
+DROP INDEX IF EXISTS idx_deployment_events_created_at;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS crash_loop_detected_at;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS next_restart_at;
@@ -0,0 +1,8 @@
+-- 000049_zero_downtime_restarts.up.sql
+-- Restart controller state for health-checked zero-downtime restarts.
+-- Restart history is stored in deployment_events.
+
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS next_restart_at TIMESTAMPTZ;
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS crash_loop_detected_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_deployment_events_created_at ON deployment_events(deployment_id, created_at);
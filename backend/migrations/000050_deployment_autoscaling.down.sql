@@ -0,0 +1,7 @@
+-- 000050_deployment_autoscaling.down.sql
+-- Rollback request-load autoscaling
+
+DROP TABLE IF EXISTS deployment_load_samples;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS last_scaled_at;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS target_latency_ms;
+ALTER TABLE native_deployments DROP COLUMN IF EXISTS target_rps_per_instance;
@@ -0,0 +1,21 @@
+-- 000050_deployment_autoscaling.up.sql
+-- Request-load autoscaling for always-on deployments. The hosting proxy
+-- writes per-deployment load samples; the autoscaler scales between
+-- min_instances and max_instances from them.
+
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS target_rps_per_instance INTEGER DEFAULT 50;
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS target_latency_ms INTEGER DEFAULT 500;
+ALTER TABLE native_deployments ADD COLUMN IF NOT EXISTS last_scaled_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS deployment_load_samples (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id VARCHAR(36) NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    latency_ms_sum BIGINT NOT NULL DEFAULT 0,
+    latency_ms_max BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_load_samples_deployment_window ON deployment_load_samples(deployment_id, window_start);
@@ -0,0 +1,7 @@
+-- 000051_comment_suggestions.down.sql
+-- Rollback comment suggestions
+
+ALTER TABLE code_comments DROP COLUMN IF EXISTS suggestion_applied_by_id;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS suggestion_applied_at;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS suggestion_base;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS suggestion;
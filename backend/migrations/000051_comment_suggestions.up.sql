@@ -0,0 +1,8 @@
+-- 000051_comment_suggestions.up.sql
+-- Suggested changes on code comments. A comment may carry replacement text
+-- for its line range; accepting it patches the file and resolves the thread.
+
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS suggestion TEXT;
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS suggestion_base TEXT;
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS suggestion_applied_at TIMESTAMPTZ;
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS suggestion_applied_by_id BIGINT;
@@ -0,0 +1,9 @@
+-- 000052_comment_tasks.down.sql
+-- Rollback comment mentions, assignment and review requests
+
+DROP INDEX IF EXISTS idx_code_comments_reviewer_id;
+DROP INDEX IF EXISTS idx_code_comments_assignee_id;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS mentions;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS reviewer_id;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS review_state;
+ALTER TABLE code_comments DROP COLUMN IF EXISTS assignee_id;
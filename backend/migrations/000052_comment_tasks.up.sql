@@ -0,0 +1,11 @@
+-- 000052_comment_tasks.up.sql
+-- Comment threads as lightweight tasks: @mentions, an assignee and a review
+-- request, all kept on the thread's root comment.
+
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS assignee_id BIGINT;
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS review_state VARCHAR(20);
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS reviewer_id BIGINT;
+ALTER TABLE code_comments ADD COLUMN IF NOT EXISTS mentions TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_code_comments_assignee_id ON code_comments(assignee_id);
+CREATE INDEX IF NOT EXISTS idx_code_comments_reviewer_id ON code_comments(reviewer_id);
@@ -0,0 +1,6 @@
+-- 000053_project_restore_points.down.sql
+-- Rollback project restore points
+
+DROP TABLE IF EXISTS project_restore_point_blobs;
+DROP TABLE IF EXISTS project_restore_point_files;
+DROP TABLE IF EXISTS project_restore_points;
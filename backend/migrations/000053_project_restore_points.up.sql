@@ -0,0 +1,38 @@
+-- 000053_project_restore_points.up.sql
+-- Project-level restore points: whole-tree snapshots with their file entries
+-- and text content shared across points by hash.
+
+CREATE TABLE IF NOT EXISTS project_restore_points (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    project_id BIGINT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    label VARCHAR(255),
+    created_by_id BIGINT,
+    file_count BIGINT,
+    total_size BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_restore_points_project_id ON project_restore_points(project_id);
+CREATE INDEX IF NOT EXISTS idx_project_restore_points_created_at ON project_restore_points(created_at);
+
+CREATE TABLE IF NOT EXISTS project_restore_point_files (
+    id BIGSERIAL PRIMARY KEY,
+    point_id BIGINT NOT NULL,
+    path VARCHAR(1024) NOT NULL,
+    name VARCHAR(255),
+    type VARCHAR(16),
+    mime_type VARCHAR(255),
+    content_hash VARCHAR(64),
+    size BIGINT,
+    is_binary BOOLEAN,
+    binary_hash VARCHAR(128)
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_restore_point_files_point_id ON project_restore_point_files(point_id);
+
+CREATE TABLE IF NOT EXISTS project_restore_point_blobs (
+    hash VARCHAR(64) PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    content TEXT
+);
@@ -0,0 +1,14 @@
+-- 000054_version_tags.down.sql
+-- Rollback version and restore point tags
+
+DROP INDEX IF EXISTS idx_project_restore_points_tag;
+ALTER TABLE project_restore_points DROP COLUMN IF EXISTS tagged_at;
+ALTER TABLE project_restore_points DROP COLUMN IF EXISTS tagged_by_id;
+ALTER TABLE project_restore_points DROP COLUMN IF EXISTS is_protected;
+ALTER TABLE project_restore_points DROP COLUMN IF EXISTS tag;
+
+DROP INDEX IF EXISTS idx_file_versions_tag;
+ALTER TABLE file_versions DROP COLUMN IF EXISTS tagged_at;
+ALTER TABLE file_versions DROP COLUMN IF EXISTS tagged_by_id;
+ALTER TABLE file_versions DROP COLUMN IF EXISTS is_protected;
+ALTER TABLE file_versions DROP COLUMN IF EXISTS tag;
@@ -0,0 +1,18 @@
+-- 000054_version_tags.up.sql
+-- Named, optionally protected tags on file versions and project restore
+-- points. Protected versions are kept by pruning and only project admins may
+-- restore or delete them.
+
+ALTER TABLE file_versions ADD COLUMN IF NOT EXISTS tag VARCHAR(100);
+ALTER TABLE file_versions ADD COLUMN IF NOT EXISTS is_protected BOOLEAN DEFAULT FALSE;
+ALTER TABLE file_versions ADD COLUMN IF NOT EXISTS tagged_by_id BIGINT;
+ALTER TABLE file_versions ADD COLUMN IF NOT EXISTS tagged_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_file_versions_tag ON file_versions(tag);
+
+ALTER TABLE project_restore_points ADD COLUMN IF NOT EXISTS tag VARCHAR(100);
+ALTER TABLE project_restore_points ADD COLUMN IF NOT EXISTS is_protected BOOLEAN;
+ALTER TABLE project_restore_points ADD COLUMN IF NOT EXISTS tagged_by_id BIGINT;
+ALTER TABLE project_restore_points ADD COLUMN IF NOT EXISTS tagged_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_project_restore_points_tag ON project_restore_points(tag);
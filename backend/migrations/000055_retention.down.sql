@@ -0,0 +1,6 @@
+-- 000055_retention.down.sql
+-- Rollback retention overrides, runs and pruned build markers
+
+ALTER TABLE IF EXISTS completed_builds DROP COLUMN IF EXISTS artifacts_pruned_at;
+DROP TABLE IF EXISTS retention_runs;
+DROP TABLE IF EXISTS retention_overrides;
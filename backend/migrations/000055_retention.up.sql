@@ -0,0 +1,40 @@
+-- 000055_retention.up.sql
+-- Retention policies: admin overrides of the per-plan limits, a record of
+-- every pruning run, and when a completed build's payloads were pruned.
+
+CREATE TABLE IF NOT EXISTS retention_overrides (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    scope VARCHAR(16) NOT NULL,
+    scope_id BIGINT NOT NULL,
+    version_days BIGINT,
+    versions_per_file BIGINT,
+    restore_point_days BIGINT,
+    build_days BIGINT,
+    exempt BOOLEAN,
+    note VARCHAR(500),
+    set_by_id BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_retention_override_scope ON retention_overrides(scope, scope_id);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    dry_run BOOLEAN,
+    triggered_by_id BIGINT,
+    projects BIGINT,
+    versions_pruned BIGINT,
+    version_bytes BIGINT,
+    restore_points_pruned BIGINT,
+    restore_point_bytes BIGINT,
+    builds_pruned BIGINT,
+    build_bytes BIGINT,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started_at ON retention_runs(started_at);
+
+ALTER TABLE IF EXISTS completed_builds ADD COLUMN IF NOT EXISTS artifacts_pruned_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- 000056_file_search_index.down.sql
+-- Rollback the full-text search index
+
+DROP TABLE IF EXISTS file_search_index;
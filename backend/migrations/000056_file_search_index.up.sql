@@ -0,0 +1,16 @@
+-- 000056_file_search_index.up.sql
+-- Full-text search index: one weighted tsvector per text file (name A,
+-- path B, content C), refreshed in batches by the search indexer when
+-- files.updated_at moves past source_updated_at.
+
+CREATE TABLE IF NOT EXISTS file_search_index (
+    file_id BIGINT PRIMARY KEY,
+    project_id BIGINT NOT NULL,
+    source_updated_at TIMESTAMPTZ,
+    indexed_at TIMESTAMPTZ,
+    stale BOOLEAN NOT NULL DEFAULT FALSE,
+    search_vector TSVECTOR
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_search_index_project_id ON file_search_index(project_id);
+CREATE INDEX IF NOT EXISTS idx_file_search_index_vector ON file_search_index USING GIN(search_vector);
@@ -0,0 +1,5 @@
+-- 000057_file_symbols.down.sql
+-- Rollback the symbol index
+
+DROP TABLE IF EXISTS file_symbol_states;
+DROP TABLE IF EXISTS file_symbols;
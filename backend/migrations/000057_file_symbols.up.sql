@@ -0,0 +1,36 @@
+-- 000057_file_symbols.up.sql
+-- Symbol index: declarations extracted from project files with tree-sitter
+-- (functions, classes, methods, types, constants), plus one state row per
+-- file recording the content they were extracted from.
+
+CREATE TABLE IF NOT EXISTS file_symbols (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL,
+    file_id BIGINT NOT NULL,
+    file_path TEXT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    container VARCHAR(255),
+    signature VARCHAR(255),
+    line BIGINT,
+    end_line BIGINT,
+    name_column BIGINT,
+    exported BOOLEAN,
+    language VARCHAR(32)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_symbols_project_name ON file_symbols(project_id, name);
+CREATE INDEX IF NOT EXISTS idx_file_symbols_file_id ON file_symbols(file_id);
+
+CREATE TABLE IF NOT EXISTS file_symbol_states (
+    file_id BIGINT PRIMARY KEY,
+    project_id BIGINT NOT NULL,
+    content_hash VARCHAR(64),
+    source_updated_at TIMESTAMPTZ,
+    language VARCHAR(32),
+    symbol_count BIGINT,
+    error VARCHAR(500),
+    indexed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_symbol_states_project_id ON file_symbol_states(project_id);
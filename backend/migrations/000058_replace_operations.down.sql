@@ -0,0 +1,4 @@
+-- 000058_replace_operations.down.sql
+-- Rollback search-and-replace undo records
+
+DROP TABLE IF EXISTS replace_operations;
@@ -0,0 +1,21 @@
+-- 000058_replace_operations.up.sql
+-- Applied search-and-replace operations, kept for 24 hours so they can be
+-- undone by restoring the file versions recorded before them.
+
+CREATE TABLE IF NOT EXISTS replace_operations (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    token VARCHAR(36) NOT NULL,
+    project_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    search TEXT,
+    replace TEXT,
+    files_modified BIGINT,
+    total_replaces BIGINT,
+    files TEXT,
+    expires_at TIMESTAMPTZ,
+    undone_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_replace_operations_token ON replace_operations(token);
+CREATE INDEX IF NOT EXISTS idx_replace_operations_project_id ON replace_operations(project_id);
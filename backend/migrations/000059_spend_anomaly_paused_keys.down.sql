@@ -0,0 +1,4 @@
+-- 000059_spend_anomaly_paused_keys.down.sql
+-- Rollback spend anomaly paused key ids
+
+ALTER TABLE spend_anomalies DROP COLUMN IF EXISTS paused_key_ids;
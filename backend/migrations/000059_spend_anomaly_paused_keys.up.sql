@@ -0,0 +1,5 @@
+-- 000059_spend_anomaly_paused_keys.up.sql
+-- Records which BYOK keys a spend anomaly deactivated so an override
+-- re-enables only those keys.
+
+ALTER TABLE spend_anomalies ADD COLUMN IF NOT EXISTS paused_key_ids TEXT;
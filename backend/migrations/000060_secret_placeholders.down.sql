@@ -0,0 +1,4 @@
+-- 000060_secret_placeholders.down.sql
+-- Rollback the secret placeholder flag
+
+ALTER TABLE secrets DROP COLUMN IF EXISTS is_placeholder;
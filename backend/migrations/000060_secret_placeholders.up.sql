@@ -0,0 +1,8 @@
+-- 000060_secret_placeholders.up.sql
+-- Flags environment secrets created without a value. Existing placeholders
+-- were only marked by the note at the end of their description.
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS is_placeholder BOOLEAN DEFAULT FALSE;
+
+UPDATE secrets SET is_placeholder = TRUE
+WHERE type = 'environment' AND description LIKE '%(placeholder: set a value before running)';
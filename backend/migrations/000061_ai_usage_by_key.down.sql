@@ -0,0 +1,11 @@
+-- 000061_ai_usage_by_key.down.sql
+-- Rollback the per-key AI usage breakdown. Rollups are rebuilt from
+-- ai_requests, so the per-key rows are cleared before the index is narrowed.
+
+DELETE FROM ai_usage_daily;
+DROP INDEX IF EXISTS idx_ai_usage_daily_key;
+ALTER TABLE ai_usage_daily DROP COLUMN IF EXISTS api_key_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_usage_daily_key ON ai_usage_daily(user_id, day_key, provider, model);
+
+DROP INDEX IF EXISTS idx_ai_requests_api_key_id;
+ALTER TABLE ai_requests DROP COLUMN IF EXISTS api_key_id;
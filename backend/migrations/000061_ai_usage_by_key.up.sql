@@ -0,0 +1,10 @@
+-- 000061_ai_usage_by_key.up.sql
+-- Records the BYOK key that served each AI request and breaks the daily
+-- usage rollup down by it. Key 0 is the platform's keys.
+
+ALTER TABLE ai_requests ADD COLUMN IF NOT EXISTS api_key_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_ai_requests_api_key_id ON ai_requests(api_key_id);
+
+ALTER TABLE ai_usage_daily ADD COLUMN IF NOT EXISTS api_key_id INTEGER NOT NULL DEFAULT 0;
+DROP INDEX IF EXISTS idx_ai_usage_daily_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_usage_daily_key ON ai_usage_daily(user_id, day_key, provider, model, api_key_id);
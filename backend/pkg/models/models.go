@@ -112,6 +112,17 @@ type Project struct {
 	IsPublic   bool `json:"is_public" gorm:"default:false"`
 	IsArchived bool `json:"is_archived" gorm:"default:false"`
 
+	// Set for organization-owned projects: members see the project per their
+	// RBAC role and it counts against the organization's quota. OwnerID is
+	// then the member who created or contributed it.
+	OrganizationID *uint `json:"organization_id,omitempty" gorm:"index"`
+
+	// Cold-storage archive: while archived, files live in a compressed object
+	// in the artifact store instead of the files table.
+	ArchivedAt       *time.Time `json:"archived_at,omitempty"`
+	ArchiveKey       string     `json:"-" gorm:"size:512"`
+	ArchiveSizeBytes int64      `json:"archive_size_bytes,omitempty" gorm:"default:0"`
+
 	// Project structure
 	RootDirectory string `json:"root_directory" gorm:"default:'/'"` // File system root path
 	EntryPoint    string `json:"entry_point"`                       // Main file (main.go, index.js, etc.)
@@ -157,6 +168,10 @@ type File struct {
 	Size    int64  `json:"size" gorm:"default:0"`    // File size in bytes
 	Hash    string `json:"hash"`                     // SHA-256 hash for change detection
 
+	// Binary files keep Content empty; their bytes live in the blob store
+	// under Hash (see storage.BlobKey).
+	IsBinary bool `json:"is_binary" gorm:"default:false"`
+
 	// Versioning
 	Version    int  `json:"version" gorm:"default:1"`
 	LastEditBy uint `json:"last_edit_by"`
@@ -209,6 +224,9 @@ type AIRequest struct {
 	// AI request details
 	Provider   string                 `json:"provider" gorm:"not null"`       // claude, gpt4, gemini
 	Capability string                 `json:"capability" gorm:"not null"`     // code_generation, code_review, etc.
+	Model      string                 `json:"model" gorm:"size:100"`          // Model that served the request
+	APIKeyID   *uint                  `json:"api_key_id" gorm:"index"`        // User's BYOK key that served it; nil for platform keys
+	Region     string                 `json:"region" gorm:"size:16;index"`    // Data residency region that served it; empty when untagged
 	Prompt     string                 `json:"prompt" gorm:"type:text"`        // User's prompt
 	Code       string                 `json:"code" gorm:"type:text"`          // Code context if provided
 	Language   string                 `json:"language"`                       // Programming language
@@ -379,6 +397,14 @@ type FileVersion struct {
 	// Retention flags
 	IsPinned   bool `json:"is_pinned" gorm:"default:false"`    // Pinned versions are never auto-deleted
 	IsAutoSave bool `json:"is_auto_save" gorm:"default:false"` // Auto-save vs manual save
+
+	// Named tag ("v1.0-submitted"), unique per file. Protected tagged
+	// versions are never auto-deleted, and only project admins may restore
+	// or delete them.
+	Tag         string     `json:"tag,omitempty" gorm:"size:100;index"`
+	IsProtected bool       `json:"is_protected" gorm:"default:false"`
+	TaggedByID  *uint      `json:"tagged_by_id,omitempty"`
+	TaggedAt    *time.Time `json:"tagged_at,omitempty"`
 }
 
 // CodeComment represents an inline code comment for collaboration (Replit parity feature)
@@ -421,6 +447,19 @@ type CodeComment struct {
 
 	// Reactions (emoji reactions to comments)
 	Reactions map[string][]uint `json:"reactions" gorm:"serializer:json"` // emoji -> user IDs
+
+	// Suggested change: replacement text for StartLine..EndLine. SuggestionBase
+	// holds the lines it was written against, so a stale suggestion is refused.
+	Suggestion            *string    `json:"suggestion,omitempty" gorm:"type:text"`
+	SuggestionBase        string     `json:"-" gorm:"type:text"`
+	SuggestionAppliedAt   *time.Time `json:"suggestion_applied_at,omitempty"`
+	SuggestionAppliedByID *uint      `json:"suggestion_applied_by_id,omitempty"`
+
+	// Task tracking, kept on the thread's root comment
+	AssigneeID  *uint  `json:"assignee_id,omitempty" gorm:"index"`
+	ReviewState string `json:"review_state,omitempty" gorm:"size:20"` // requested, approved, changes_requested
+	ReviewerID  *uint  `json:"reviewer_id,omitempty" gorm:"index"`
+	Mentions    []uint `json:"mentions,omitempty" gorm:"serializer:json"` // mentioned user IDs
 }
 
 // UserCollabRoom represents the many-to-many relationship between users and collaboration rooms
@@ -462,6 +501,9 @@ type RefreshToken struct {
 	UserAgent string `json:"user_agent"`             // User agent that created this token
 	DeviceID  string `json:"device_id" gorm:"index"` // Optional device identifier
 
+	// Session activity; refreshed on authenticated requests at most once a minute
+	LastActiveAt *time.Time `json:"last_active_at"`
+
 	// Token family for detecting token reuse attacks
 	// If a used token is presented again, we revoke the entire family
 	FamilyID string `json:"family_id" gorm:"index;not null;size:36"` // UUID linking related tokens
@@ -487,6 +529,9 @@ type UserAPIKey struct {
 
 	// User preferences for this provider
 	ModelPreference string `json:"model_preference" gorm:"size:100"` // e.g. "grok-3", "claude-sonnet-4-6"
+	// CapabilityModels overrides ModelPreference per use: "completions",
+	// "chat", "build" or a build role such as "build:frontend".
+	CapabilityModels map[string]string `json:"capability_models,omitempty" gorm:"serializer:json;type:text"`
 
 	// Status and tracking
 	IsActive             bool       `json:"is_active" gorm:"default:true"`
@@ -557,6 +602,32 @@ type ProjectAsset struct {
 	StoragePath string `json:"storage_path" gorm:"size:512;not null"`
 }
 
+// FileUpload tracks a resumable chunked upload of a large project file.
+// Chunks are stored under "uploads/<id>/" in the storage provider until the
+// last one arrives, when they are assembled into a blob and the file row is
+// created or replaced.
+type FileUpload struct {
+	ID        string    `json:"id" gorm:"primarykey;size:36"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint   `json:"project_id" gorm:"not null;index"`
+	UserID    uint   `json:"user_id" gorm:"not null;index"`
+	Path      string `json:"path" gorm:"not null"`
+	MimeType  string `json:"mime_type" gorm:"size:127"`
+
+	// Size is the declared total; Received counts bytes accepted so far.
+	Size     int64 `json:"size" gorm:"not null"`
+	Received int64 `json:"received" gorm:"not null;default:0"`
+
+	// ReplaceFileID is the file the upload overwrites (0 creates a new file).
+	// ReplaceVersion is the version matched by If-Match; 0 overwrites any.
+	ReplaceFileID  uint `json:"replace_file_id,omitempty"`
+	ReplaceVersion int  `json:"-"`
+
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
 // CompletedBuild stores a completed build for history and retrieval
 type CompletedBuild struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -599,6 +670,7 @@ type CompletedBuild struct {
 	DurationMs          int64                  `json:"duration_ms" gorm:"default:0"` // Build duration in milliseconds
 	Error               string                 `json:"error,omitempty" gorm:"type:text"`
 	CompletedAt         *time.Time             `json:"completed_at,omitempty"`
+	ArtifactsPrunedAt   *time.Time             `json:"artifacts_pruned_at,omitempty"` // JSON payloads cleared by retention
 }
 
 // PromptPackActivationRequest stores admin-gated prompt-pack activation intent
@@ -690,7 +762,7 @@ type CreditLedgerEntry struct {
 	UserID          uint      `json:"user_id" gorm:"not null;index"`
 	AmountUSD       float64   `json:"amount_usd" gorm:"not null"` // positive = credit, negative = debit
 	BalanceAfterUSD float64   `json:"balance_after_usd" gorm:"not null"`
-	// Entry type: monthly_allocation | credit_purchase | admin_grant | spend_deduction | refund
+	// Entry type: monthly_allocation | credit_purchase | admin_grant | spend_deduction | refund | referral_reward
 	EntryType   string `json:"entry_type" gorm:"not null;size:32;index"`
 	Description string `json:"description" gorm:"size:255"`
 	// Stripe references (for reconciliation)
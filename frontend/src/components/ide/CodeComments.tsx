@@ -20,6 +20,7 @@ import {
   ChevronDown,
   ChevronUp,
   AlertCircle,
+  GitPullRequestArrow,
 } from 'lucide-react'
 
 // Common emoji reactions
@@ -39,6 +40,7 @@ export interface CodeCommentsProps {
   currentUserId: number
   currentUsername: string
   onCommentClick?: (line: number) => void
+  onSuggestionApplied?: (content: string) => void
   className?: string
 }
 
@@ -55,6 +57,7 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
   currentUserId,
   currentUsername,
   onCommentClick,
+  onSuggestionApplied,
   className,
 }) => {
   const [comments, setComments] = useState<CodeComment[]>([])
@@ -63,9 +66,11 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
   const [error, setError] = useState<string | null>(null)
   const [selectedThread, setSelectedThread] = useState<string | null>(null)
   const [showResolved, setShowResolved] = useState(false)
+  const [assignedToMe, setAssignedToMe] = useState(false)
   const [isCreating, setIsCreating] = useState(false)
   const [newCommentLine, setNewCommentLine] = useState<number | null>(null)
   const [newCommentContent, setNewCommentContent] = useState('')
+  const [newSuggestion, setNewSuggestion] = useState<string | null>(null)
   const [replyingTo, setReplyingTo] = useState<number | null>(null)
   const [replyContent, setReplyContent] = useState('')
   const [editingComment, setEditingComment] = useState<number | null>(null)
@@ -77,7 +82,7 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
     if (file?.id) {
       fetchComments()
     }
-  }, [file?.id, showResolved]) // eslint-disable-line react-hooks/exhaustive-deps -- fetch helper is intentionally local for readability.
+  }, [file?.id, showResolved, assignedToMe]) // eslint-disable-line react-hooks/exhaustive-deps -- fetch helper is intentionally local for readability.
 
   // Set up WebSocket listener for real-time updates
   // Note: When WebSocket comment events are implemented, they should trigger fetchComments()
@@ -97,6 +102,7 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
     try {
       const response = await apiService.getFileComments(file.id, {
         include_resolved: showResolved,
+        assigned: assignedToMe ? 'me' : undefined,
       })
       setComments(response.comments)
       updateMarkers(response.comments)
@@ -142,9 +148,11 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
         start_line: newCommentLine,
         end_line: newCommentLine,
         content: newCommentContent.trim(),
+        ...(newSuggestion !== null ? { suggestion: newSuggestion } : {}),
       })
 
       setNewCommentContent('')
+      setNewSuggestion(null)
       setNewCommentLine(null)
       await fetchComments()
     } catch (err: any) {
@@ -236,6 +244,34 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
     }
   }
 
+  const handleApplySuggestion = async (commentId: number) => {
+    try {
+      const result = await apiService.applyCommentSuggestion(commentId)
+      onSuggestionApplied?.(result.content)
+      await fetchComments()
+    } catch (err: any) {
+      setError(err.response?.data?.error || err.message || 'Failed to apply suggestion')
+    }
+  }
+
+  const handleAssign = async (commentId: number, assigneeId: number | null) => {
+    try {
+      await apiService.assignCommentThread(commentId, assigneeId)
+      await fetchComments()
+    } catch (err: any) {
+      setError(err.response?.data?.error || err.message || 'Failed to assign thread')
+    }
+  }
+
+  const handleReview = async (commentId: number, state: 'approved' | 'changes_requested') => {
+    try {
+      await apiService.setCommentReview(commentId, state)
+      await fetchComments()
+    } catch (err: any) {
+      setError(err.response?.data?.error || err.message || 'Failed to update review')
+    }
+  }
+
   const handleAddReaction = async (commentId: number, emoji: string) => {
     try {
       await apiService.addCommentReaction(commentId, emoji)
@@ -258,12 +294,14 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
   const startNewComment = (line: number) => {
     setNewCommentLine(line)
     setNewCommentContent('')
+    setNewSuggestion(null)
     setSelectedThread(null)
   }
 
   const cancelNewComment = () => {
     setNewCommentLine(null)
     setNewCommentContent('')
+    setNewSuggestion(null)
   }
 
   const getThreadComments = (threadId: string): CodeComment[] => {
@@ -301,6 +339,18 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
             {comment.is_resolved && !isReply && (
               <Badge variant="success" size="xs">Resolved</Badge>
             )}
+            {!isReply && comment.assignee_id && (
+              <Badge variant="outline" size="xs">
+                {comment.assignee_id === currentUserId ? 'Assigned to you' : 'Assigned'}
+              </Badge>
+            )}
+            {!isReply && comment.review_state && (
+              <Badge variant={comment.review_state === 'approved' ? 'success' : 'secondary'} size="xs">
+                {comment.review_state === 'requested'
+                  ? 'Review requested'
+                  : comment.review_state === 'approved' ? 'Approved' : 'Changes requested'}
+              </Badge>
+            )}
           </div>
 
           {isAuthor && !isEditing && (
@@ -364,6 +414,36 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
           </p>
         )}
 
+        {/* Suggested change */}
+        {!isEditing && comment.suggestion !== undefined && comment.suggestion !== null && (
+          <div className="mt-2 rounded border border-green-500/30 bg-green-500/5">
+            <div className="flex items-center justify-between px-2 py-1 border-b border-green-500/20">
+              <span className="text-xs text-green-300">
+                Suggested change
+                {comment.start_line === comment.end_line
+                  ? ` (line ${comment.start_line})`
+                  : ` (lines ${comment.start_line}-${comment.end_line})`}
+              </span>
+              {comment.suggestion_applied_at ? (
+                <Badge variant="outline" size="xs">Applied</Badge>
+              ) : !comment.is_resolved && (
+                <Button
+                  size="xs"
+                  variant="ghost"
+                  onClick={() => handleApplySuggestion(comment.id)}
+                  icon={<GitPullRequestArrow size={12} />}
+                  className="text-green-400 hover:text-green-300"
+                >
+                  Apply
+                </Button>
+              )}
+            </div>
+            <pre className="px-2 py-1 text-xs text-green-200 font-mono whitespace-pre-wrap">
+              {comment.suggestion || '(delete these lines)'}
+            </pre>
+          </div>
+        )}
+
         {/* Reactions */}
         {!isEditing && Object.keys(reactions).length > 0 && (
           <div className="flex flex-wrap gap-1 mt-2">
@@ -431,6 +511,37 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
               )}
             </div>
 
+            <Button
+              size="xs"
+              variant="ghost"
+              onClick={() => handleAssign(
+                comment.id,
+                comment.assignee_id === currentUserId ? null : currentUserId
+              )}
+            >
+              {comment.assignee_id === currentUserId ? 'Unassign' : 'Assign to me'}
+            </Button>
+
+            {comment.review_state === 'requested' && comment.reviewer_id === currentUserId && (
+              <>
+                <Button
+                  size="xs"
+                  variant="ghost"
+                  onClick={() => handleReview(comment.id, 'approved')}
+                  className="text-green-400 hover:text-green-300"
+                >
+                  Approve
+                </Button>
+                <Button
+                  size="xs"
+                  variant="ghost"
+                  onClick={() => handleReview(comment.id, 'changes_requested')}
+                >
+                  Request changes
+                </Button>
+              </>
+            )}
+
             {!comment.is_resolved ? (
               <Button
                 size="xs"
@@ -549,15 +660,27 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
             </Badge>
           </div>
 
-          <label className="flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
-            <input
-              type="checkbox"
-              checked={showResolved}
-              onChange={(e) => setShowResolved(e.target.checked)}
-              className="rounded border-gray-600 bg-gray-800 text-cyan-500 focus:ring-cyan-500"
-            />
-            Show resolved
-          </label>
+          <div className="flex items-center gap-3">
+            <label className="flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
+              <input
+                type="checkbox"
+                checked={showResolved}
+                onChange={(e) => setShowResolved(e.target.checked)}
+                className="rounded border-gray-600 bg-gray-800 text-cyan-500 focus:ring-cyan-500"
+              />
+              Show resolved
+            </label>
+
+            <label className="flex items-center gap-2 text-xs text-gray-400 cursor-pointer">
+              <input
+                type="checkbox"
+                checked={assignedToMe}
+                onChange={(e) => setAssignedToMe(e.target.checked)}
+                className="rounded border-gray-600 bg-gray-800 text-cyan-500 focus:ring-cyan-500"
+              />
+              Assigned to me
+            </label>
+          </div>
         </div>
       </div>
 
@@ -671,7 +794,29 @@ export const CodeComments: React.FC<CodeCommentsProps> = ({
             autoFocus
           />
 
+          {newSuggestion !== null && (
+            <textarea
+              value={newSuggestion}
+              onChange={(e) => setNewSuggestion(e.target.value)}
+              placeholder="Replacement for this line (leave empty to delete it)"
+              className="w-full bg-gray-900 border border-green-600/50 rounded-lg px-3 py-2 text-xs font-mono text-green-200 placeholder:text-gray-500 focus:border-green-400 focus:outline-none resize-none mb-3"
+              rows={3}
+            />
+          )}
+
           <div className="flex justify-end gap-2">
+            <Button
+              size="sm"
+              variant="ghost"
+              onClick={() => setNewSuggestion(
+                newSuggestion === null
+                  ? (file.content || '').split('\n')[newCommentLine - 1] ?? ''
+                  : null
+              )}
+              icon={<GitPullRequestArrow size={14} />}
+            >
+              {newSuggestion === null ? 'Suggest change' : 'Remove suggestion'}
+            </Button>
             <Button
               size="sm"
               variant="ghost"
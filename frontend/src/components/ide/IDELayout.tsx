@@ -391,11 +391,18 @@ export const IDELayout: React.FC<IDELayoutProps> = ({
         const file = files.find(f => f.id === fileId)
         if (file) {
           try {
-            await apiService.post('/preview/hot-reload', {
+            const response = await apiService.post<{ mode?: 'hmr' | 'reload'; dev_server?: boolean }>('/preview/hot-reload', {
               project_id: currentProject.id,
               file_path: file.path,
               content: content
             })
+            // A dev server without a connected HMR socket cannot patch the
+            // page in place, so fall back to a full reload
+            if (response.data?.dev_server && response.data.mode === 'reload') {
+              window.dispatchEvent(new CustomEvent('apex:preview-reload', {
+                detail: { projectId: currentProject.id },
+              }))
+            }
           } catch {
             try {
               await apiService.post('/preview/refresh', {
@@ -609,6 +616,17 @@ export const IDELayout: React.FC<IDELayoutProps> = ({
     }
   }, [activeFile, layout.panes, paneUpdateFileContent, paneMarkFileSaved])
 
+  // Handle an applied comment suggestion: the server already saved the file
+  const handleSuggestionApplied = useCallback((fileId: number, content: string) => {
+    layout.panes.forEach(pane => {
+      if (pane.files.find(f => f.file.id === fileId)) {
+        paneUpdateFileContent(fileId, content, pane.id)
+        paneMarkFileSaved(fileId, pane.id)
+      }
+    })
+    setTerminalOutput(prev => [...prev, 'Applied suggested change'])
+  }, [layout.panes, paneUpdateFileContent, paneMarkFileSaved])
+
   // Terminal command execution
   const executeTerminalCommand = useCallback(async (command: string) => {
     setTerminalOutput(prev => [...prev, `$ ${command}`])
@@ -732,6 +750,7 @@ export const IDELayout: React.FC<IDELayoutProps> = ({
             onCommentClick={(line) => {
               splitPaneRef.current?.revealLine(line)
             }}
+            onSuggestionApplied={(content) => handleSuggestionApplied(activeFile.id, content)}
             className="h-full border-0"
           />
         ) : (
@@ -85,6 +85,27 @@ const frameworkConfig: Record<string, { name: string; color: string }> = {
   vite: { name: 'Vite', color: '#646cff' },
 };
 
+type ImportSource = 'github' | 'gitlab' | 'bitbucket' | 'archive';
+
+// Which importer handles a URL. GitHub keeps its validate-first flow; the
+// other sources are downloaded as archives by /projects/import/repository
+// and /projects/import/archive.
+const importSourceFor = (input: string): ImportSource | null => {
+  const value = input.trim().toLowerCase();
+  if (/^https?:\/\/.+\.(zip|tar\.gz|tgz)(\?.*)?$/.test(value)) return 'archive';
+  if (/^(https?:\/\/|git@)?(www\.)?gitlab\.com[/:]/.test(value)) return 'gitlab';
+  if (/^(https?:\/\/|git@)?(www\.)?bitbucket\.org[/:]/.test(value)) return 'bitbucket';
+  if (/^(https?:\/\/)?(github\.com\/)?[\w-]+\/[\w.-]+\/?$/.test(value.replace('.git', ''))) return 'github';
+  return null;
+};
+
+const importEndpoints: Record<ImportSource, string> = {
+  github: '/projects/import/github',
+  gitlab: '/projects/import/repository',
+  bitbucket: '/projects/import/repository',
+  archive: '/projects/import/archive',
+};
+
 interface GitHubImportWizardProps {
   onClose?: () => void;
   onImported?: (projectId: number) => void;
@@ -116,16 +137,27 @@ export const GitHubImportWizard: React.FC<GitHubImportWizardProps> = ({ onClose,
     }
 
     // Quick client-side validation
-    const urlPattern = /^(https?:\/\/)?(github\.com\/)?[\w-]+\/[\w.-]+\/?$/;
-    if (!urlPattern.test(inputUrl.replace('.git', ''))) {
+    const source = importSourceFor(inputUrl);
+    if (!source) {
       setValidation({
         valid: false,
-        error: 'Invalid GitHub URL format',
-        hint: 'Expected format: github.com/owner/repo or https://github.com/owner/repo',
+        error: 'Unsupported URL format',
+        hint: 'Expected a GitHub, GitLab or Bitbucket repository URL, or a link to a .zip / .tar.gz archive',
       });
       return;
     }
 
+    // GitLab, Bitbucket and archive URLs are checked when the import runs.
+    if (source !== 'github') {
+      const segments = inputUrl.trim().replace(/\.git$/, '').replace(/\/+$/, '').split(/[/:]/);
+      const name = (segments[segments.length - 1] || '').replace(/\.(zip|tar\.gz|tgz)(\?.*)?$/i, '');
+      setValidation({ valid: true, owner: source, repo: name, name });
+      setProjectName(name);
+      setDescription('');
+      setStep('configure');
+      return;
+    }
+
     setStep('validating');
 
     try {
@@ -187,7 +219,7 @@ export const GitHubImportWizard: React.FC<GitHubImportWizardProps> = ({ onClose,
     }, 500);
 
     try {
-      const response = await apiService.post('/projects/import/github', {
+      const response = await apiService.post(importEndpoints[importSourceFor(url) ?? 'github'], {
         url,
         project_name: projectName,
         description,
@@ -210,6 +242,26 @@ export const GitHubImportWizard: React.FC<GitHubImportWizardProps> = ({ onClose,
     }
   };
 
+  // Import a local .zip / .tar.gz archive
+  const handleArchiveUpload = async (archive: File) => {
+    setStep('importing');
+    setProgress(10);
+    try {
+      const data = await apiService.importArchiveUpload(archive, {
+        is_public: canPublishProjects && isPublic,
+      });
+      setImportResult(data);
+      setProgress(100);
+      setStep('success');
+    } catch (err) {
+      const message =
+        (err as any)?.response?.data?.error ||
+        (err instanceof Error ? err.message : 'Import failed');
+      setError(message);
+      setStep('error');
+    }
+  };
+
   // Reset wizard
   const handleReset = () => {
     setStep('url');
@@ -295,14 +347,14 @@ export const GitHubImportWizard: React.FC<GitHubImportWizardProps> = ({ onClose,
             <div className="space-y-4">
               <div>
                 <label className="block text-sm font-medium text-gray-300 mb-2">
-                  GitHub Repository URL
+                  Repository or Archive URL
                 </label>
                 <div className="relative">
                   <input
                     type="text"
                     value={url}
                     onChange={(e) => setUrl(e.target.value)}
-                    placeholder="github.com/owner/repo or paste full URL"
+                    placeholder="github.com/owner/repo, gitlab.com/group/project, bitbucket.org/team/repo or a .zip URL"
                     className="w-full px-4 py-3 bg-gray-900 border border-gray-600 rounded-lg text-white placeholder-gray-500 focus:outline-none focus:ring-2 focus:ring-cyan-500 focus:border-transparent"
                     disabled={step === 'validating'}
                   />
@@ -356,6 +408,21 @@ export const GitHubImportWizard: React.FC<GitHubImportWizardProps> = ({ onClose,
               >
                 {step === 'validating' ? 'Validating...' : 'Continue'}
               </button>
+
+              {/* Local archive upload */}
+              <label className="block text-center text-sm text-gray-400 cursor-pointer hover:text-gray-300">
+                or <span className="text-cyan-400">upload a .zip or .tar.gz</span>
+                <input
+                  type="file"
+                  accept=".zip,.tar.gz,.tgz,application/zip,application/gzip"
+                  className="hidden"
+                  disabled={step === 'validating'}
+                  onChange={(e) => {
+                    const archive = e.target.files?.[0];
+                    if (archive) void handleArchiveUpload(archive);
+                  }}
+                />
+              </label>
             </div>
           )}
 
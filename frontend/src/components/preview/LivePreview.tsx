@@ -202,6 +202,19 @@ export default function LivePreview({
     return () => document.removeEventListener('fullscreenchange', handleFullscreenChange)
   }, [])
 
+  // Saves a dev server could not hot-apply ask for a full reload
+  const { refreshPreview } = runtime
+  useEffect(() => {
+    const handlePreviewReload = (event: Event) => {
+      const detail = (event as CustomEvent<{ projectId?: number }>).detail
+      if (detail?.projectId === projectId) {
+        void refreshPreview()
+      }
+    }
+    window.addEventListener('apex:preview-reload', handlePreviewReload)
+    return () => window.removeEventListener('apex:preview-reload', handlePreviewReload)
+  }, [projectId, refreshPreview])
+
   const openInNewTab = () => {
     if (!previewSrc) return
     window.open(previewSrc, '_blank')
@@ -20,6 +20,30 @@ export interface ServerStatus {
   exited_at?: string
   exit_code?: number
   last_error?: string
+  /** Names of project env vars and secrets injected into the process */
+  injected_env?: string[]
+  /** Port the preview proxy targets, when remapped from the assigned one */
+  primary_port?: number
+  /** Extra ports exposed under /preview/port-proxy/:projectId/:name */
+  exposed_ports?: Record<string, number>
+}
+
+export interface ListeningPort {
+  port: number
+  source: 'assigned' | 'socket' | 'logs'
+  primary: boolean
+  exposed?: string
+}
+
+export interface PortMapping {
+  primary_port: number
+  exposed: Record<string, number>
+}
+
+export interface ServerPorts {
+  ports: ListeningPort[]
+  mapping: PortMapping
+  urls: Record<string, string>
 }
 
 export interface ServerDetection {
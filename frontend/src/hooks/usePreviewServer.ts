@@ -1,6 +1,6 @@
 import { useCallback, useEffect, useRef, useState } from 'react'
 import apiService from '@/services/api'
-import type { ServerDetection, ServerStatus } from '@/components/preview/types'
+import type { PortMapping, ServerDetection, ServerPorts, ServerStatus } from '@/components/preview/types'
 
 interface UsePreviewServerOptions {
   projectId: number
@@ -14,6 +14,7 @@ export function usePreviewServer({ projectId, setError }: UsePreviewServerOption
   const [serverLoading, setServerLoading] = useState(false)
   const [serverLogs, setServerLogs] = useState<{ stdout: string; stderr: string }>({ stdout: '', stderr: '' })
   const [showServerLogs, setShowServerLogs] = useState(false)
+  const [serverPorts, setServerPorts] = useState<ServerPorts | null>(null)
 
   useEffect(() => {
     setServerStatus(null)
@@ -21,6 +22,7 @@ export function usePreviewServer({ projectId, setError }: UsePreviewServerOption
     setServerLoading(false)
     setServerLogs({ stdout: '', stderr: '' })
     setShowServerLogs(false)
+    setServerPorts(null)
   }, [projectId])
 
   useEffect(() => {
@@ -106,6 +108,25 @@ export function usePreviewServer({ projectId, setError }: UsePreviewServerOption
     }
   }, [projectId])
 
+  const fetchServerPorts = useCallback(async () => {
+    try {
+      const response = await apiService.client.get(`/preview/server/ports/${projectId}`)
+      setServerPorts(response.data)
+    } catch {
+      setServerPorts(null)
+    }
+  }, [projectId])
+
+  const updateServerPorts = useCallback(async (mapping: PortMapping) => {
+    try {
+      const response = await apiService.client.put(`/preview/server/ports/${projectId}`, mapping)
+      setServerPorts(response.data)
+      await fetchServerStatus()
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to update port mapping')
+    }
+  }, [fetchServerStatus, projectId, setError])
+
   const toggleServerLogs = useCallback(async () => {
     const next = !showServerLogs
     setShowServerLogs(next)
@@ -145,6 +166,9 @@ export function usePreviewServer({ projectId, setError }: UsePreviewServerOption
     stopServer,
     fetchServerLogs,
     toggleServerLogs,
+    serverPorts,
+    fetchServerPorts,
+    updateServerPorts,
   }
 }
 
@@ -19,6 +19,11 @@ import {
   AIUsage,
   Execution,
   ExecutionResult,
+  ExecutionArtifact,
+  EnvironmentLockCheck,
+  EnvironmentLockDrift,
+  EnvVarCheck,
+  EnvPlaceholderSecret,
   LoginRequest,
   RegisterRequest,
   AuthResponse,
@@ -29,25 +34,40 @@ import {
   AIProvider,
   ExploreData,
   ProjectWithStats,
+  PublicFile,
+  PublicFileNode,
+  ProjectShareLinks,
   ProjectComment,
   ProjectCategory,
   UserPublicProfile,
   UserFollowInfo,
   CodeComment,
   CodeCommentThread,
+  CodeCommentFilters,
+  CodeCommentReviewState,
   CreateCommentRequest,
   UpdateCommentRequest,
   FileVersion,
+  FileVersionTag,
   VersionDiff,
   ManagedDatabase,
   CreateDatabaseRequest,
   DatabaseMetrics,
   TableInfo,
   ColumnInfo,
+  RedisKeyPage,
+  RedisQueueReport,
+  RedisChannel,
+  RedisMessage,
+  RedisMemoryStats,
   CompletionRequest,
   CompletionResponse,
   CompletionItem,
   CompletionStats,
+  CompletionOutcome,
+  CompletionAnalytics,
+  CompletionSettings,
+  FastModelStatus,
   Organization,
   OrganizationMember,
   Role,
@@ -184,6 +204,13 @@ const removeHeaderValue = (headers: AxiosRequestConfig['headers'], key: string):
   return headerStore
 }
 
+const appendCommentFilters = (params: URLSearchParams, filters?: CodeCommentFilters): void => {
+  if (filters?.status) params.append('status', filters.status)
+  if (filters?.assigned !== undefined) params.append('assigned', String(filters.assigned))
+  if (filters?.review) params.append('review', filters.review)
+  if (filters?.reviewer) params.append('reviewer', filters.reviewer)
+}
+
 export interface FeatureReadinessService {
   name: string
   tier: 'critical' | 'optional'
@@ -304,6 +331,8 @@ export class ApiService {
   private refreshPromise: Promise<TokenResponse> | null = null
   private csrfToken: string | null = null
   private csrfTokenFetchPromise: Promise<void> | null = null
+  // Last ETag seen per file id, sent as If-Match so concurrent edits get a 412
+  private fileETags = new Map<number, string>()
 
   constructor(baseURL: string = getApiUrl()) {
     this.baseURL = baseURL
@@ -448,6 +477,104 @@ export class ApiService {
     return response.data.map
   }
 
+  async getAdminAnalyticsTrends(params?: { from?: string; to?: string }): Promise<AdminAnalyticsTrends> {
+    const response = await this.client.get<{ success: boolean; data: AdminAnalyticsTrends }>('/admin/analytics/trends', { params })
+    return response.data.data
+  }
+
+  async getAdminAnalyticsPlans(params?: { from?: string; to?: string }): Promise<{ from: string; to: string; rows: PlanDailyMetric[] }> {
+    const response = await this.client.get('/admin/analytics/plans', { params })
+    return response.data.data
+  }
+
+  async getAdminAnalyticsCohorts(params?: { from?: string; to?: string }): Promise<{ from: string; to: string; cohorts: RetentionCohort[] }> {
+    const response = await this.client.get('/admin/analytics/cohorts', { params })
+    return response.data.data
+  }
+
+  async getAdminAnalyticsExports(limit?: number): Promise<{ sinks: string[]; exports: WarehouseExport[] }> {
+    const response = await this.client.get('/admin/analytics/exports', { params: limit ? { limit } : undefined })
+    return response.data.data
+  }
+
+  async rebuildAdminAnalytics(data: { from: string; to: string; export?: boolean }): Promise<{ from: string; to: string; export: boolean }> {
+    const response = await this.client.post('/admin/analytics/rebuild', data)
+    return response.data.data
+  }
+
+  async getAdminAbuseCases(params?: { status?: AbuseCaseStatus; limit?: number }): Promise<{ cases: AbuseQueueItem[] }> {
+    const response = await this.client.get('/admin/abuse/cases', { params })
+    return response.data.data
+  }
+
+  async getAdminAbuseCase(caseId: number): Promise<{ case: AbuseCase; signals: AbuseSignal[] }> {
+    const response = await this.client.get(`/admin/abuse/cases/${caseId}`)
+    return response.data.data
+  }
+
+  async resolveAdminAbuseCase(caseId: number, data: { action: 'clear' | 'throttle' | 'suspend'; note?: string }): Promise<AbuseCase> {
+    const response = await this.client.post(`/admin/abuse/cases/${caseId}/resolve`, data)
+    return response.data.data
+  }
+
+  async getAdminQuotaOverrides(params?: { subject_type?: QuotaOverrideSubject; subject_id?: number; status?: QuotaOverrideStatus; limit?: number }): Promise<{ overrides: QuotaOverride[] }> {
+    const response = await this.client.get('/admin/quota-overrides', { params })
+    return response.data.data
+  }
+
+  async createAdminQuotaOverride(data: {
+    subject_type: QuotaOverrideSubject
+    subject_id: number
+    usage_type: UsageType
+    mode: QuotaOverrideMode
+    amount: number
+    reason: string
+    starts_at?: string
+    expires_at?: string
+  }): Promise<QuotaOverride> {
+    const response = await this.client.post('/admin/quota-overrides', data)
+    return response.data.data
+  }
+
+  async revokeAdminQuotaOverride(overrideId: number, note?: string): Promise<QuotaOverride> {
+    const response = await this.client.delete(`/admin/quota-overrides/${overrideId}`, { data: note ? { note } : undefined })
+    return response.data.data
+  }
+
+  async getAdminRetentionPolicies(): Promise<{ plans: Record<string, RetentionPolicy>; overrides: RetentionOverride[] }> {
+    const response = await this.client.get('/admin/retention/policies')
+    return response.data.data
+  }
+
+  async setAdminRetentionOverride(data: RetentionOverrideInput): Promise<RetentionOverride> {
+    const response = await this.client.put('/admin/retention/overrides', data)
+    return response.data.data
+  }
+
+  async deleteAdminRetentionOverride(overrideId: number): Promise<void> {
+    await this.client.delete(`/admin/retention/overrides/${overrideId}`)
+  }
+
+  async runAdminRetention(data?: { dry_run?: boolean }): Promise<RetentionReport> {
+    const response = await this.client.post('/admin/retention/run', data ?? {})
+    return response.data.data
+  }
+
+  async getAdminSearchIndexStats(params?: { project_id?: number }): Promise<SearchIndexStats> {
+    const response = await this.client.get<{ success: boolean; stats: SearchIndexStats }>('/admin/search/index', { params })
+    return response.data.stats
+  }
+
+  async rebuildAdminSearchIndex(data?: { project_id?: number }): Promise<{ marked: number }> {
+    const response = await this.client.post<{ success: boolean; marked: number }>('/admin/search/index/rebuild', data ?? {})
+    return { marked: response.data.marked }
+  }
+
+  async getAdminRetentionRuns(params?: { limit?: number }): Promise<{ runs: RetentionRun[] }> {
+    const response = await this.client.get('/admin/retention/runs', { params })
+    return response.data.data
+  }
+
   async getBuildArchitectureReferences(buildId: string): Promise<ArchitectureReferenceTelemetry> {
     const response = await this.client.get<{ references: ArchitectureReferenceTelemetry }>(`/build/${buildId}/architecture-references`)
     return response.data.references
@@ -542,6 +669,69 @@ export class ApiService {
     return response.data.data!.user
   }
 
+  // Sessions (signed-in devices)
+  async getSessions(): Promise<UserSession[]> {
+    const response = await this.client.get<{ success: boolean; sessions: UserSession[] }>('/user/sessions')
+    return response.data.sessions
+  }
+
+  async revokeSession(sessionId: string): Promise<{ success: boolean; current: boolean }> {
+    const response = await this.client.delete(`/user/sessions/${encodeURIComponent(sessionId)}`)
+    return response.data
+  }
+
+  async revokeAllSessions(includeCurrent = false): Promise<{ success: boolean; revoked: number }> {
+    const response = await this.client.post('/user/sessions/revoke-all', {}, {
+      params: includeCurrent ? { include_current: true } : undefined,
+    })
+    return response.data
+  }
+
+  // Account privacy (GDPR/CCPA)
+  async exportAccountData(): Promise<Blob> {
+    const response = await this.client.get('/account/export', {
+      responseType: 'blob',
+      timeout: 0,
+    })
+    return response.data
+  }
+
+  /**
+   * Queue a backup of every project the user owns. Allowed once per day; the user is emailed when it is ready.
+   */
+  async requestProjectExport(): Promise<ProjectExport> {
+    const response = await this.client.post<{ success: boolean; data: ProjectExport }>('/account/project-exports')
+    return response.data.data
+  }
+
+  async listProjectExports(): Promise<ProjectExport[]> {
+    const response = await this.client.get<{ success: boolean; data: ProjectExport[] }>('/account/project-exports')
+    return response.data.data || []
+  }
+
+  async downloadProjectExport(exportId: number): Promise<Blob> {
+    const response = await this.client.get(`/account/project-exports/${exportId}/download`, {
+      responseType: 'blob',
+      timeout: 0,
+    })
+    return response.data
+  }
+
+  async requestAccountDeletion(password: string): Promise<any> {
+    const response = await this.client.post('/account/deletion', { password })
+    return response.data
+  }
+
+  async confirmAccountDeletion(code: string): Promise<any> {
+    const response = await this.client.post('/account/deletion/confirm', { code })
+    return response.data
+  }
+
+  async cancelAccountDeletion(): Promise<any> {
+    const response = await this.client.delete('/account/deletion')
+    return response.data
+  }
+
   // Project endpoints
   async createProject(data: {
     name: string
@@ -668,6 +858,69 @@ export class ApiService {
     await this.client.delete(`/projects/${id}`)
   }
 
+  async archiveProject(id: number): Promise<any> {
+    const response = await this.client.post(`/projects/${id}/archive`)
+    return response.data
+  }
+
+  async unarchiveProject(id: number): Promise<any> {
+    const response = await this.client.post(`/projects/${id}/unarchive`)
+    return response.data
+  }
+
+  // Project AI instructions (apex.md), applied to builds, completions and chat
+  async getProjectInstructions(id: number): Promise<{ success: boolean; data: ProjectInstructionsResponse }> {
+    const response = await this.client.get(`/projects/${id}/instructions`)
+    return response.data
+  }
+
+  async updateProjectInstructions(id: number, content: string): Promise<{ success: boolean; data: ProjectInstructionsResponse }> {
+    const response = await this.client.put(`/projects/${id}/instructions`, { content })
+    return response.data
+  }
+
+  // Organization-owned projects and ownership transfers
+  async createOrgProject(orgId: number, data: {
+    name: string
+    description?: string
+    language?: string
+    framework?: string
+    is_public?: boolean
+  }): Promise<any> {
+    const response = await this.client.post(`/enterprise/organizations/${orgId}/projects`, data)
+    return response.data
+  }
+
+  async getOrgProjects(orgId: number): Promise<any> {
+    const response = await this.client.get(`/enterprise/organizations/${orgId}/projects`)
+    return response.data
+  }
+
+  async transferProject(id: number, target: { to_user_id?: number; to_organization_id?: number }): Promise<any> {
+    const response = await this.client.post(`/projects/${id}/transfers`, target)
+    return response.data
+  }
+
+  async getProjectTransfers(): Promise<any> {
+    const response = await this.client.get('/project-transfers')
+    return response.data
+  }
+
+  async acceptProjectTransfer(transferId: number): Promise<any> {
+    const response = await this.client.post(`/project-transfers/${transferId}/accept`)
+    return response.data
+  }
+
+  async declineProjectTransfer(transferId: number): Promise<any> {
+    const response = await this.client.post(`/project-transfers/${transferId}/decline`)
+    return response.data
+  }
+
+  async cancelProjectTransfer(transferId: number): Promise<any> {
+    const response = await this.client.delete(`/project-transfers/${transferId}`)
+    return response.data
+  }
+
   // File endpoints
   async createFile(projectId: number, data: {
     path: string
@@ -681,7 +934,9 @@ export class ApiService {
       data
     )
     const dataValue = response.data.data as any
-    return response.data.file || dataValue?.file || dataValue || (response.data as unknown as File)
+    const file = response.data.file || dataValue?.file || dataValue || (response.data as unknown as File)
+    this.rememberFileETag(file?.id, response.headers?.etag)
+    return file
   }
 
   async getFiles(projectId: number): Promise<File[]> {
@@ -691,18 +946,250 @@ export class ApiService {
     return response.data.files || response.data.data || []
   }
 
+  /**
+   * List a project's file tree without contents. Served from the versioned tree cache when unchanged.
+   */
+  async getFileTree(projectId: number): Promise<{ files: File[]; total: number; tree_version?: string; cached?: boolean }> {
+    const response = await this.client.get<{ files: File[]; total: number; tree_version?: string; cached?: boolean }>(
+      `/projects/${projectId}/files`
+    )
+    return response.data
+  }
+
   async getFile(id: number): Promise<File> {
     const response = await this.client.get<{ file?: File; data?: File }>(`/files/${id}`)
+    this.rememberFileETag(id, response.headers?.etag)
     return response.data.file || response.data.data || (response.data as unknown as File)
   }
 
-  async updateFile(id: number, data: { content?: string; name?: string; path?: string }): Promise<File> {
-    await this.client.put(`/files/${id}`, data)
+  /**
+   * Saves a file with If-Match. Uses the ETag from the last load or save of
+   * this file unless one is passed; `force` overwrites regardless. A stale
+   * ETag rejects with 412 and a `diff` hint in the response body.
+   */
+  async updateFile(
+    id: number,
+    data: { content?: string; name?: string; path?: string },
+    options: { etag?: string; force?: boolean } = {}
+  ): Promise<File> {
+    let ifMatch = options.force ? '*' : options.etag || this.fileETags.get(id)
+    if (!ifMatch) {
+      // Files loaded through a list have no tracked ETag yet.
+      await this.getFile(id)
+      ifMatch = this.fileETags.get(id) || '*'
+    }
+    const response = await this.client.put(`/files/${id}`, data, {
+      headers: { 'If-Match': ifMatch },
+    })
+    this.rememberFileETag(id, response.headers?.etag)
     return this.getFile(id)
   }
 
+  /**
+   * Uploads a binary file (image, font, ...) to `path`. Replacing an existing
+   * file sends its tracked ETag as If-Match unless `force` is set.
+   */
+  async uploadBinaryFile(
+    projectId: number,
+    path: string,
+    nativeFile: Blob,
+    options: { replaceId?: number; etag?: string; force?: boolean } = {}
+  ): Promise<File> {
+    const formData = new FormData()
+    formData.append('file', nativeFile)
+    formData.append('path', path)
+    const headers: Record<string, string> = { 'Content-Type': 'multipart/form-data' }
+    const ifMatch = options.force
+      ? '*'
+      : options.etag || (options.replaceId ? this.fileETags.get(options.replaceId) : undefined)
+    if (ifMatch) {
+      headers['If-Match'] = ifMatch
+    }
+    const response = await this.client.post<{ file: File }>(
+      `/projects/${projectId}/files/upload`,
+      formData,
+      { headers }
+    )
+    this.rememberFileETag(response.data.file?.id, response.headers?.etag)
+    return response.data.file
+  }
+
+  /**
+   * Upload a large file in resumable chunks. A failed chunk is retried from
+   * the offset the server reports, so flaky connections don't restart the
+   * whole upload.
+   */
+  async uploadLargeFile(
+    projectId: number,
+    path: string,
+    nativeFile: Blob,
+    options: {
+      replaceId?: number
+      etag?: string
+      force?: boolean
+      onProgress?: (progress: number) => void
+    } = {}
+  ): Promise<File> {
+    const headers: Record<string, string> = {}
+    const ifMatch = options.force
+      ? '*'
+      : options.etag || (options.replaceId ? this.fileETags.get(options.replaceId) : undefined)
+    if (ifMatch) {
+      headers['If-Match'] = ifMatch
+    }
+    const created = await this.client.post<{ upload: FileUpload; chunk_size: number }>(
+      `/projects/${projectId}/uploads`,
+      { path, size: nativeFile.size, mime_type: nativeFile.type || undefined },
+      { headers }
+    )
+    const uploadUrl = `/uploads/${created.data.upload.id}`
+    const chunkSize = created.data.chunk_size
+    let offset = 0
+    let retries = 0
+    for (;;) {
+      try {
+        const response = await this.client.patch<{ file?: File }>(
+          uploadUrl,
+          nativeFile.slice(offset, offset + chunkSize),
+          {
+            headers: {
+              'Content-Type': 'application/offset+octet-stream',
+              'Upload-Offset': String(offset),
+            },
+          }
+        )
+        offset = Number(response.headers?.['upload-offset'] ?? offset + chunkSize)
+        options.onProgress?.(Math.round((offset / nativeFile.size) * 100))
+        if (response.data.file) {
+          this.rememberFileETag(response.data.file.id, response.headers?.etag)
+          return response.data.file
+        }
+        retries = 0
+      } catch (error: any) {
+        const status = error?.response?.status
+        if ((status && status !== 409 && status < 500) || ++retries > 5) {
+          throw error
+        }
+        const head = await this.client.head(uploadUrl)
+        offset = Number(head.headers?.['upload-offset'] ?? offset)
+      }
+    }
+  }
+
+  async cancelUpload(uploadId: string): Promise<void> {
+    await this.client.delete(`/uploads/${uploadId}`)
+  }
+
+  /** URL of a file's raw bytes (binary or text), for <img src> and downloads. */
+  getFileRawUrl(id: number): string {
+    return `${this.baseURL}/files/${id}/raw`
+  }
+
+  private rememberFileETag(id: number | undefined, etag: unknown): void {
+    if (id && typeof etag === 'string' && etag) {
+      this.fileETags.set(id, etag)
+    }
+  }
+
   async deleteFile(id: number): Promise<void> {
     await this.client.delete(`/files/${id}`)
+    this.fileETags.delete(id)
+  }
+
+  // Trash endpoints (deleted projects/files are kept for 30 days)
+
+  async getTrash(): Promise<any> {
+    const response = await this.client.get('/trash')
+    return response.data
+  }
+
+  async restoreTrashedProject(id: number): Promise<any> {
+    const response = await this.client.post(`/trash/projects/${id}/restore`)
+    return response.data
+  }
+
+  async restoreTrashedFile(id: number): Promise<any> {
+    const response = await this.client.post(`/trash/files/${id}/restore`)
+    return response.data
+  }
+
+  async purgeTrashedProject(id: number): Promise<void> {
+    await this.client.delete(`/trash/projects/${id}`)
+  }
+
+  async purgeTrashedFile(id: number): Promise<void> {
+    await this.client.delete(`/trash/files/${id}`)
+  }
+
+  async emptyTrash(): Promise<any> {
+    const response = await this.client.delete('/trash')
+    return response.data
+  }
+
+  // Agent marketplace endpoints (custom agent roles, moderated before install)
+
+  async searchAgentMarketplace(params?: { q?: string; role?: string; limit?: number }): Promise<{ success: boolean; data: { agents: AgentListing[] } }> {
+    const response = await this.client.get('/agent-marketplace', { params })
+    return response.data
+  }
+
+  async getMyMarketplaceAgents(): Promise<{ success: boolean; data: { agents: AgentListing[] } }> {
+    const response = await this.client.get('/agent-marketplace/mine')
+    return response.data
+  }
+
+  async getInstalledMarketplaceAgents(): Promise<{ success: boolean; data: { installed: InstalledMarketplaceAgent[] } }> {
+    const response = await this.client.get('/agent-marketplace/installed')
+    return response.data
+  }
+
+  async publishMarketplaceAgent(data: {
+    slug: string
+    name: string
+    description?: string
+    tags?: string[]
+    version: string
+    changelog?: string
+    config: AgentProfile
+  }): Promise<{ success: boolean; data: { agent: AgentListing; version: AgentListingVersion } }> {
+    const response = await this.client.post('/agent-marketplace', data)
+    return response.data
+  }
+
+  async publishMarketplaceAgentVersion(slug: string, data: {
+    version: string
+    changelog?: string
+    config: AgentProfile
+  }): Promise<{ success: boolean; data: { agent: AgentListing; version: AgentListingVersion } }> {
+    const response = await this.client.post(`/agent-marketplace/${slug}/versions`, data)
+    return response.data
+  }
+
+  async getMarketplaceAgent(slug: string): Promise<{ success: boolean; data: { agent: AgentListing; versions: AgentListingVersion[] } }> {
+    const response = await this.client.get(`/agent-marketplace/${slug}`)
+    return response.data
+  }
+
+  async checkMarketplaceAgentCompatibility(slug: string, version?: string): Promise<{
+    success: boolean
+    data: { version: string; compatible: boolean; issues: string[] | null }
+  }> {
+    const response = await this.client.get(`/agent-marketplace/${slug}/compatibility`, { params: version ? { version } : undefined })
+    return response.data
+  }
+
+  async installMarketplaceAgent(slug: string, version?: string): Promise<any> {
+    const response = await this.client.post(`/agent-marketplace/${slug}/install`, version ? { version } : {})
+    return response.data
+  }
+
+  async uninstallMarketplaceAgent(slug: string): Promise<void> {
+    await this.client.delete(`/agent-marketplace/${slug}/install`)
+  }
+
+  async rateMarketplaceAgent(slug: string, stars: number, comment?: string): Promise<{ success: boolean; data: { agent: AgentListing } }> {
+    const response = await this.client.post(`/agent-marketplace/${slug}/rating`, { stars, comment })
+    return response.data
   }
 
   // AI endpoints
@@ -737,28 +1224,104 @@ export class ApiService {
     return response.data
   }
 
-  async getAIHistory(limit: number = 50, offset: number = 0): Promise<AIRequest[]> {
-    const response = await this.client.get<ApiResponse<{ requests: AIRequest[] }>>(
-      `/ai/history?limit=${limit}&offset=${offset}`
-    )
-    return response.data.data!.requests
+  async getAIProviderHealth(): Promise<{
+    providers: Record<string, {
+      status?: string
+      detail?: string
+      circuit: {
+        state: 'closed' | 'open' | 'half_open'
+        calls: number
+        error_rate: number
+        slow_call_rate: number
+        avg_latency_ms: number
+        recent_errors?: Record<string, number>
+        last_error?: string
+        last_error_at?: string
+        trips: number
+        trip_reason?: string
+        opened_at?: string
+        retry_at?: string
+      }
+    }>
+    checked_at: string
+  }> {
+    const response = await this.client.get('/ai/providers/health')
+    return response.data
   }
 
-  async rateAIResponse(requestId: string, rating: number, feedback?: string): Promise<void> {
-    await this.client.post(`/ai/rate/${requestId}`, { rating, feedback })
+  async getAIAnalytics(params: {
+    from?: string
+    to?: string
+    group_by?: string
+    provider?: string
+    model?: string
+  } = {}): Promise<any> {
+    const response = await this.client.get('/ai/analytics', { params })
+    return response.data
   }
 
-  // Build endpoints (Agent Orchestration System)
+  async exportAIAnalyticsCSV(params: {
+    from?: string
+    to?: string
+    group_by?: string
+  } = {}): Promise<Blob> {
+    const response = await this.client.get('/ai/analytics', {
+      params: { ...params, format: 'csv' },
+      responseType: 'blob',
+    })
+    return response.data
+  }
 
-  async buildPreflight(data?: {
-    description?: string
-    prompt?: string
-    provider_mode?: 'platform' | 'byok'
-    require_preview_ready?: boolean
-    tech_stack?: {
-      frontend?: string
-      backend?: string
-      database?: string
+  // Notification center
+
+  async getNotifications(params: { unread?: boolean; limit?: number } = {}): Promise<any> {
+    const response = await this.client.get('/notifications', { params })
+    return response.data
+  }
+
+  async markNotificationRead(id: number): Promise<void> {
+    await this.client.post(`/notifications/${id}/read`)
+  }
+
+  async markAllNotificationsRead(): Promise<any> {
+    const response = await this.client.post('/notifications/read-all')
+    return response.data
+  }
+
+  // Spend anomaly alerts
+
+  async getSpendAnomalies(limit: number = 50): Promise<any> {
+    const response = await this.client.get('/budget/anomalies', { params: { limit } })
+    return response.data
+  }
+
+  async overrideSpendAnomaly(id: number): Promise<any> {
+    const response = await this.client.post(`/budget/anomalies/${id}/override`)
+    return response.data
+  }
+
+  async getAIHistory(limit: number = 50, offset: number = 0): Promise<AIRequest[]> {
+    const response = await this.client.get<ApiResponse<{ requests: AIRequest[] }>>(
+      `/ai/history?limit=${limit}&offset=${offset}`
+    )
+    return response.data.data!.requests
+  }
+
+  async rateAIResponse(requestId: string, rating: number, feedback?: string): Promise<void> {
+    await this.client.post(`/ai/rate/${requestId}`, { rating, feedback })
+  }
+
+  // Build endpoints (Agent Orchestration System)
+
+  async buildPreflight(data?: {
+    description?: string
+    prompt?: string
+    provider_mode?: 'platform' | 'byok'
+    require_preview_ready?: boolean
+    tech_stack?: {
+      frontend?: string
+      backend?: string
+      database?: string
       styling?: string
       extras?: string[]
     }
@@ -791,7 +1354,7 @@ export class ApiService {
     description: string
     prompt?: string
     mode: 'fast' | 'full'
-    power_mode?: 'fast' | 'balanced' | 'max' | 'auto'
+    power_mode?: 'fast' | 'balanced' | 'max' | 'auto' | 'budget'
     provider_mode?: 'platform' | 'byok'
     require_preview_ready?: boolean
     tech_stack?: {
@@ -808,9 +1371,18 @@ export class ApiService {
     mobile_capabilities?: MobileCapability[]
     mobile_dependency_policy?: string
     mobile_app_spec?: unknown
+    i18n?: {
+      enabled: boolean
+      framework?: 'i18next' | 'react-intl'
+      base_locale?: string
+      locales?: string[]
+    }
+    approval_gates?: BuildApprovalGate[]
+    agent_profiles?: AgentProfile[]
     diff_mode?: boolean
     role_assignments?: Record<string, string>
     provider_model_overrides?: Record<string, string>
+    max_files?: number
     wireframe_image?: string
   }): Promise<{
     build_id: string
@@ -822,6 +1394,21 @@ export class ApiService {
     return response.data
   }
 
+  async translateBuildLocales(buildId: string, data: {
+    base_locale?: string
+    target_locales: string[]
+  }): Promise<{
+    build_id: string
+    base_locale: string
+    provider?: string
+    files: Array<{ path: string; content: string; language: string; size: number; is_new: boolean }>
+    failed?: string[]
+    applied: boolean
+  }> {
+    const response = await this.client.post(`/build/${buildId}/i18n/translate`, data)
+    return response.data
+  }
+
   async getBuildStatus(buildId: string): Promise<any> {
     const response = await this.client.get(`/build/${buildId}/status`)
     return response.data
@@ -899,11 +1486,63 @@ export class ApiService {
     return response.data
   }
 
+  async getBuildApprovals(buildId: string): Promise<{
+    gates: BuildApprovalGate[] | null
+    requests: BuildApprovalRequest[] | null
+    live: boolean
+  }> {
+    const response = await this.client.get(`/builds/${buildId}/approvals`)
+    return response.data
+  }
+
+  async resolveBuildApproval(buildId: string, data: {
+    decision: 'approve' | 'reject'
+    request_id?: string
+    note?: string
+    plan?: Record<string, unknown>
+  }): Promise<{
+    interaction: BuildInteractionState
+    request: BuildApprovalRequest
+  }> {
+    const response = await this.client.post(`/builds/${buildId}/approvals`, data)
+    return response.data
+  }
+
+  async getBuildPlan(buildId: string): Promise<{ plan: BuildPlanOutline }> {
+    const response = await this.client.get(`/builds/${buildId}/plan`)
+    return response.data
+  }
+
+  async editBuildPlan(buildId: string, edit: BuildPlanEdit): Promise<{ plan: BuildPlanOutline }> {
+    const response = await this.client.patch(`/builds/${buildId}/plan`, edit)
+    return response.data
+  }
+
+  async getBuildProvenance(buildId: string): Promise<{ provenance: BuildProvenance }> {
+    const response = await this.client.get(`/builds/${buildId}/provenance`)
+    return response.data
+  }
+
+  async getBuildFileProvenance(buildId: string, path: string): Promise<{ build_id: string; file: BuildFileProvenance }> {
+    const response = await this.client.get(`/builds/${buildId}/provenance`, { params: { path } })
+    return response.data
+  }
+
+  async getBuildConsensus(buildId: string): Promise<{ build_id: string; rounds: ConsensusRound[]; live: boolean }> {
+    const response = await this.client.get(`/builds/${buildId}/consensus`)
+    return response.data
+  }
+
+  async compareBuilds(baseBuildId: string, headBuildId: string): Promise<{ comparison: BuildComparison }> {
+    const response = await this.client.get(`/builds/${baseBuildId}/compare/${headBuildId}`)
+    return response.data
+  }
+
   async pauseBuild(buildId: string, reason?: string): Promise<{
     status: string
     interaction: BuildInteractionState
   }> {
-    const response = await this.client.post(`/build/${buildId}/pause`, reason ? { reason } : {})
+    const response = await this.client.post(`/builds/${buildId}/pause`, reason ? { reason } : {})
     return response.data
   }
 
@@ -911,7 +1550,7 @@ export class ApiService {
     status: string
     interaction: BuildInteractionState
   }> {
-    const response = await this.client.post(`/build/${buildId}/resume`, reason ? { reason } : {})
+    const response = await this.client.post(`/builds/${buildId}/resume`, reason ? { reason } : {})
     return response.data
   }
 
@@ -1071,7 +1710,7 @@ export class ApiService {
   }
 
   async cancelBuild(buildId: string): Promise<void> {
-    await this.client.post(`/build/${buildId}/cancel`)
+    await this.client.post(`/builds/${buildId}/cancel`)
   }
 
   async deleteBuild(buildId: string): Promise<void> {
@@ -1115,14 +1754,40 @@ export class ApiService {
     return response.data
   }
 
+  // Preview runtime diagnostics
+  async getPreviewDiagnostics(projectId: number, since?: number): Promise<PreviewDiagnosticsResponse> {
+    const response = await this.client.get<PreviewDiagnosticsResponse>(`/preview/diagnostics/${projectId}`, {
+      params: since ? { since } : undefined,
+    })
+    return response.data
+  }
+
+  async clearPreviewDiagnostics(projectId: number): Promise<void> {
+    await this.client.delete(`/preview/diagnostics/${projectId}`)
+  }
+
+  async solvePreviewDiagnostic(
+    projectId: number,
+    diagnosticId: number
+  ): Promise<{ analysis: string; files: string[]; provider: string }> {
+    const response = await this.client.post<{
+      success: boolean
+      analysis: string
+      files: string[]
+      provider: string
+    }>(`/preview/diagnostics/${projectId}/${diagnosticId}/solve`)
+    return { analysis: response.data.analysis, files: response.data.files || [], provider: response.data.provider }
+  }
+
   // Build history endpoints
-  async listBuilds(page = 1, limit = 20): Promise<{
+  async listBuilds(page = 1, limit = 20, params?: ListParams): Promise<{
     builds: CompletedBuildSummary[]
     total: number
     page: number
     limit: number
+    page_info?: PageInfo
   }> {
-    const response = await this.client.get('/builds', { params: { page, limit } })
+    const response = await this.client.get('/builds', { params: { page, limit, ...params } })
     return response.data
   }
 
@@ -1147,8 +1812,10 @@ export class ApiService {
   async executeProject(data: {
     project_id: number
     command?: string
+    mode?: 'run' | 'test'
     env?: Record<string, string>
     timeout?: number
+    capture_artifacts?: boolean
   }): Promise<ExecutionResult> {
     const response = await this.client.post<{ data?: ExecutionResult }>(
       '/execute/project',
@@ -1162,6 +1829,25 @@ export class ApiService {
     return response.data.data || (response.data as unknown as Execution)
   }
 
+  // Files a project run wrote, kept for 24 hours after a capture_artifacts run
+  async getExecutionArtifacts(executionId: string): Promise<ExecutionArtifact[]> {
+    const response = await this.client.get<{ data: { artifacts: ExecutionArtifact[] } }>(
+      `/execute/${executionId}/artifacts`
+    )
+    return response.data.data.artifacts
+  }
+
+  // Save captured files into the project; all of them when paths is omitted
+  async applyExecutionArtifacts(executionId: string, paths?: string[]): Promise<{
+    execution_id: string
+    project_id: number
+    applied: string[]
+    skipped?: Record<string, string>
+  }> {
+    const response = await this.client.post(`/execute/${executionId}/artifacts/apply`, { paths })
+    return response.data.data
+  }
+
   async getExecutionHistory(
     projectId: number,
     limit: number = 50,
@@ -1258,6 +1944,7 @@ export class ApiService {
     description?: string
     is_private?: boolean
     token: string
+    sign_commits?: boolean
   }): Promise<{
     success: boolean
     data?: {
@@ -1267,6 +1954,7 @@ export class ApiService {
       commit_sha: string
       branch: string
       file_count: number
+      signed?: boolean
     }
     error?: string
     message?: string
@@ -1293,6 +1981,28 @@ export class ApiService {
 
   // ========== GIT INTEGRATION ENDPOINTS ==========
 
+  // Commit signing uses the GIT_SIGNING_KEY secret (GPG or SSH)
+  async getGitCommitSigning(projectId: number): Promise<{
+    success: boolean
+    enabled: boolean
+    key: {
+      format: 'gpg' | 'ssh'
+      fingerprint: string
+      public_key: string
+      name?: string
+      email?: string
+    } | null
+    key_error?: string
+  }> {
+    const response = await this.client.get(`/git/signing/${projectId}`)
+    return response.data
+  }
+
+  async setGitCommitSigning(projectId: number, enabled: boolean): Promise<{ success: boolean }> {
+    const response = await this.client.put(`/git/signing/${projectId}`, { enabled })
+    return response.data
+  }
+
   async getGitRepo(projectId: number): Promise<{
     success: boolean
     repository: {
@@ -1355,8 +2065,87 @@ export class ApiService {
     return response.data
   }
 
-  async gitPull(projectId: number): Promise<{ success: boolean; message?: string }> {
-    const response = await this.client.post('/git/pull', { project_id: projectId })
+  /**
+   * Pulls the connected branch. The default merge strategy rejects with 409
+   * (code MERGE_CONFLICT, body includes `conflicts`) when files changed on
+   * both sides; 'theirs' overwrites the project with the remote.
+   */
+  async gitPull(projectId: number, strategy: 'merge' | 'theirs' = 'merge'): Promise<{ success: boolean; message?: string }> {
+    const response = await this.client.post('/git/pull', { project_id: projectId, strategy })
+    return response.data
+  }
+
+  async getGitConflicts(projectId: number): Promise<{ success: boolean; conflicts: GitConflictDetail[]; count: number }> {
+    const response = await this.client.get(`/git/conflicts/${projectId}`)
+    return response.data
+  }
+
+  async resolveGitConflict(
+    projectId: number,
+    path: string,
+    resolution: 'ours' | 'theirs' | 'manual',
+    content?: string
+  ): Promise<{ success: boolean; conflict: GitSyncConflict; remaining: number }> {
+    const response = await this.client.post(`/git/conflicts/${projectId}/resolve`, { path, resolution, content })
+    return response.data
+  }
+
+  async suggestGitConflictResolution(projectId: number, path: string): Promise<{
+    success: boolean
+    path: string
+    suggestion: string
+    provider: string
+  }> {
+    const response = await this.client.post(`/git/conflicts/${projectId}/suggest`, { path })
+    return response.data
+  }
+
+  async commitGitConflictResolution(projectId: number, message?: string): Promise<{
+    success: boolean
+    commit: any
+    message: string
+  }> {
+    const response = await this.client.post(`/git/conflicts/${projectId}/commit`, { message })
+    return response.data
+  }
+
+  /**
+   * Build a change from a GitHub issue on the project's connected repository,
+   * optionally opening a pull request that closes it
+   */
+  async buildFromGitIssue(projectId: number, issueUrl: string, options: { baseBranch?: string } = {}): Promise<{
+    success: boolean
+    build: GitIssueBuild
+    issue?: GitIssue
+    message?: string
+  }> {
+    const response = await this.client.post(`/git/issues/${projectId}/build`, {
+      issue_url: issueUrl,
+      base_branch: options.baseBranch,
+    })
+    return response.data
+  }
+
+  async getGitIssueBuilds(projectId: number): Promise<{ success: boolean; builds: GitIssueBuild[] }> {
+    const response = await this.client.get(`/git/issues/${projectId}/builds`)
+    return response.data
+  }
+
+  async getGitSyncStatus(projectId: number): Promise<GitSyncStatus> {
+    const response = await this.client.get(`/git/sync/${projectId}`)
+    return response.data
+  }
+
+  async setGitAutoSync(projectId: number, enabled: boolean): Promise<{ success: boolean; repository: any }> {
+    const response = await this.client.put(`/git/sync/${projectId}`, { enabled })
+    return response.data
+  }
+
+  async gitSyncNow(projectId: number): Promise<{
+    success: boolean
+    result: { commit_sha: string; updated: string[]; merged: string[]; deleted: string[]; conflicts: GitSyncConflict[] }
+  }> {
+    const response = await this.client.post(`/git/sync/${projectId}`)
     return response.data
   }
 
@@ -1514,6 +2303,63 @@ export class ApiService {
     }))
   }
 
+  async getSearchIndexStats(projectId: number): Promise<SearchIndexStats> {
+    const response = await this.client.get<{ success: boolean; stats: SearchIndexStats }>('/search/index/stats', {
+      params: { project_id: projectId },
+    })
+    return response.data.stats
+  }
+
+  // Searches every project the user can read, or one organization's, grouped by project
+  async searchWorkspace(query: string, options?: { scope?: 'user' | 'org'; organization_id?: number; case_sensitive?: boolean; regex?: boolean; file_types?: string[]; max_results?: number }): Promise<WorkspaceSearchResults> {
+    const response = await this.client.post<{ success: boolean; scope: string; results: WorkspaceSearchResults }>('/search/workspace', {
+      query,
+      scope: options?.scope,
+      organization_id: options?.organization_id,
+      case_sensitive: options?.case_sensitive,
+      use_regex: options?.regex,
+      file_types: options?.file_types,
+      max_results: options?.max_results,
+      include_content: true,
+      context_lines: 0,
+      search_type: 'content',
+    })
+    return response.data.results
+  }
+
+  // Two-phase replace: preview every match, apply a selection, undo by token
+  async previewReplace(request: ReplaceRequest): Promise<ReplacePreview> {
+    const response = await this.client.post<{ success: boolean; preview: ReplacePreview }>('/search/replace/preview', request)
+    return response.data.preview
+  }
+
+  async applyReplace(request: ReplaceRequest & { selections?: ReplaceSelection[] }): Promise<ReplaceApplyResult> {
+    const response = await this.client.post<{ success: boolean; result: ReplaceApplyResult }>('/search/replace/apply', request)
+    return response.data.result
+  }
+
+  async undoReplace(projectId: number, undoToken: string, force = false): Promise<ReplaceUndoResult> {
+    const response = await this.client.post<{ success: boolean; result: ReplaceUndoResult }>('/search/replace/undo', {
+      project_id: projectId,
+      undo_token: undoToken,
+      force,
+    })
+    return response.data.result
+  }
+
+  // Go-to-symbol across a project; an empty query lists exported symbols first
+  async searchProjectSymbols(projectId: number, query: string, params?: { kind?: SymbolKind[]; file_id?: number; limit?: number }): Promise<ProjectSymbol[]> {
+    const response = await this.client.get(`/projects/${projectId}/symbols`, {
+      params: { q: query, kind: params?.kind?.join(','), file_id: params?.file_id, limit: params?.limit },
+    })
+    return response.data.data.symbols
+  }
+
+  async getFileOutline(projectId: number, path: string): Promise<SymbolOutlineNode[]> {
+    const response = await this.client.get(`/projects/${projectId}/symbols/outline`, { params: { path } })
+    return response.data.data.outline || []
+  }
+
   // ========== COMMUNITY/SHARING MARKETPLACE ENDPOINTS ==========
 
   // Explore page data
@@ -1580,11 +2426,32 @@ export class ApiService {
     categories: string[]
     readme: string
     comments: ProjectComment[]
+    share?: ProjectShareLinks
   }> {
     const response = await this.client.get(`/project/${username}/${projectName}`)
     return response.data
   }
 
+  // Read-only code viewer for public projects
+  async getPublicFileTree(username: string, projectName: string): Promise<{
+    project_id: number
+    language: string
+    share_url: string
+    tree: PublicFileNode[]
+  }> {
+    const response = await this.client.get(`/project/${username}/${projectName}/tree`)
+    return response.data
+  }
+
+  async getPublicFile(username: string, projectName: string, path: string): Promise<{
+    project_id: number
+    file: PublicFile
+    embed?: { url: string; html: string }
+  }> {
+    const response = await this.client.get(`/project/${username}/${projectName}/file`, { params: { path } })
+    return response.data
+  }
+
   // Star/unstar project
   async starProject(projectId: number): Promise<void> {
     await this.client.post(`/projects/${projectId}/star`)
@@ -1712,6 +2579,22 @@ export class ApiService {
     await this.client.delete(`/versions/${versionId}`)
   }
 
+  // An empty tag removes it; new tags are protected unless `protected` is false
+  async tagFileVersion(versionId: number, tag: string, isProtected?: boolean): Promise<FileVersionTag> {
+    const response = await this.client.put<ApiResponse<FileVersionTag>>(
+      `/versions/${versionId}/tag`,
+      { tag, protected: isProtected }
+    )
+    return response.data.data!
+  }
+
+  async getProjectVersionTags(projectId: number): Promise<FileVersionTag[]> {
+    const response = await this.client.get<ApiResponse<{ tags: FileVersionTag[] }>>(
+      `/versions/project/${projectId}/tags`
+    )
+    return response.data.data!.tags
+  }
+
   async getVersionDiff(oldVersionId: number, newVersionId: number): Promise<VersionDiff> {
     const response = await this.client.get<ApiResponse<{ diff: VersionDiff }>>(
       `/versions/diff/${oldVersionId}/${newVersionId}`
@@ -1797,6 +2680,62 @@ export class ApiService {
     return response.data.data!
   }
 
+  async getRedisKeys(
+    projectId: number,
+    dbId: number,
+    params?: { pattern?: string; cursor?: number; count?: number }
+  ): Promise<RedisKeyPage> {
+    const response = await this.client.get<ApiResponse<RedisKeyPage>>(
+      `/projects/${projectId}/databases/${dbId}/redis/keys`,
+      { params }
+    )
+    return response.data.data!
+  }
+
+  async getRedisQueues(projectId: number, dbId: number): Promise<RedisQueueReport> {
+    const response = await this.client.get<ApiResponse<RedisQueueReport>>(
+      `/projects/${projectId}/databases/${dbId}/redis/queues`
+    )
+    return response.data.data!
+  }
+
+  async getRedisChannels(projectId: number, dbId: number, pattern?: string): Promise<RedisChannel[]> {
+    const response = await this.client.get<ApiResponse<{ channels: RedisChannel[] }>>(
+      `/projects/${projectId}/databases/${dbId}/redis/channels`,
+      { params: { pattern } }
+    )
+    return response.data.data!.channels
+  }
+
+  async peekRedisChannel(
+    projectId: number,
+    dbId: number,
+    channel: string,
+    options?: { wait?: number; max?: number }
+  ): Promise<RedisMessage[]> {
+    const response = await this.client.get<ApiResponse<{ channel: string; messages: RedisMessage[] }>>(
+      `/projects/${projectId}/databases/${dbId}/redis/channels/peek`,
+      { params: { channel, ...options } }
+    )
+    return response.data.data!.messages
+  }
+
+  async getRedisMemory(projectId: number, dbId: number): Promise<RedisMemoryStats> {
+    const response = await this.client.get<ApiResponse<RedisMemoryStats>>(
+      `/projects/${projectId}/databases/${dbId}/redis/memory`
+    )
+    return response.data.data!
+  }
+
+  async flushRedis(projectId: number, dbId: number, confirm: string): Promise<{ deleted_keys: number }> {
+    const response = await this.client.post<ApiResponse<{ deleted_keys: number }>>(
+      `/projects/${projectId}/databases/${dbId}/redis/flush`,
+      undefined,
+      { params: { confirm } }
+    )
+    return response.data.data!
+  }
+
   // ========== GITHUB IMPORT WIZARD ENDPOINTS ==========
 
   // Validate GitHub URL and get repo info
@@ -1854,6 +2793,49 @@ export class ApiService {
     return response.data
   }
 
+  // Import a GitHub, GitLab or Bitbucket repository. Without a token, the
+  // backend uses a stored secret (secret_name, or e.g. GITLAB_TOKEN).
+  async importRepository(data: {
+    url: string
+    provider?: 'github' | 'gitlab' | 'bitbucket'
+    branch?: string
+    project_name?: string
+    description?: string
+    is_public?: boolean
+    token?: string
+    secret_name?: string
+  }): Promise<ProjectImportResult> {
+    const response = await this.client.post('/projects/import/repository', data)
+    return response.data
+  }
+
+  // Import a .zip or .tar.gz archive from a URL
+  async importArchiveUrl(data: {
+    url: string
+    project_name?: string
+    description?: string
+    is_public?: boolean
+  }): Promise<ProjectImportResult> {
+    const response = await this.client.post('/projects/import/archive', data)
+    return response.data
+  }
+
+  // Import an uploaded .zip or .tar.gz archive
+  async importArchiveUpload(
+    archive: Blob,
+    options: { project_name?: string; description?: string; is_public?: boolean } = {}
+  ): Promise<ProjectImportResult> {
+    const formData = new FormData()
+    formData.append('file', archive)
+    if (options.project_name) formData.append('project_name', options.project_name)
+    if (options.description) formData.append('description', options.description)
+    if (options.is_public) formData.append('is_public', 'true')
+    const response = await this.client.post('/projects/import/archive/upload', formData, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    })
+    return response.data
+  }
+
   // ========== CODE COMMENTS ENDPOINTS (Replit parity) ==========
 
   // Create a new comment or reply
@@ -1866,7 +2848,7 @@ export class ApiService {
   async getFileComments(fileId: number, options?: {
     include_resolved?: boolean
     line?: number
-  }): Promise<{ file_id: number; comments: CodeComment[]; total: number }> {
+  } & CodeCommentFilters): Promise<{ file_id: number; comments: CodeComment[]; total: number }> {
     const params = new URLSearchParams()
     if (options?.include_resolved !== undefined) {
       params.append('include_resolved', options.include_resolved.toString())
@@ -1874,6 +2856,7 @@ export class ApiService {
     if (options?.line) {
       params.append('line', options.line.toString())
     }
+    appendCommentFilters(params, options)
     const query = params.toString() ? `?${params.toString()}` : ''
     const response = await this.client.get<ApiResponse<{ file_id: number; comments: CodeComment[]; total: number }>>(
       `/comments/file/${fileId}${query}`
@@ -1881,10 +2864,48 @@ export class ApiService {
     return response.data.data!
   }
 
-  // Get a specific thread
-  async getCommentThread(threadId: string): Promise<CodeCommentThread> {
-    const response = await this.client.get<ApiResponse<CodeCommentThread>>(
-      `/comments/thread/${threadId}`
+  // List a project's comment threads across files, e.g. open and assigned to me
+  async getProjectComments(projectId: number, options?: CodeCommentFilters & {
+    sort?: 'created_at' | 'updated_at'
+    limit?: number
+    cursor?: string
+  }): Promise<{ comments: CodeComment[]; total: number; page_info: PageInfo }> {
+    const params = new URLSearchParams()
+    appendCommentFilters(params, options)
+    if (options?.sort) params.append('sort', options.sort)
+    if (options?.limit) params.append('limit', options.limit.toString())
+    if (options?.cursor) params.append('cursor', options.cursor)
+    const query = params.toString() ? `?${params.toString()}` : ''
+    const response = await this.client.get(`/comments/project/${projectId}${query}`)
+    return {
+      comments: response.data.data.comments,
+      total: response.data.data.total,
+      page_info: response.data.page_info,
+    }
+  }
+
+  // Assign a comment thread to a collaborator, or unassign it with null
+  async assignCommentThread(commentId: number, assigneeId: number | null): Promise<CodeComment> {
+    const response = await this.client.put<ApiResponse<CodeComment>>(
+      `/comments/${commentId}/assign`,
+      { assignee_id: assigneeId }
+    )
+    return response.data.data!
+  }
+
+  // Request a review of a thread, record the verdict, or clear it with ''
+  async setCommentReview(commentId: number, state: CodeCommentReviewState | '', reviewerId?: number): Promise<CodeComment> {
+    const response = await this.client.put<ApiResponse<CodeComment>>(
+      `/comments/${commentId}/review`,
+      { state, reviewer_id: reviewerId }
+    )
+    return response.data.data!
+  }
+
+  // Get a specific thread
+  async getCommentThread(threadId: string): Promise<CodeCommentThread> {
+    const response = await this.client.get<ApiResponse<CodeCommentThread>>(
+      `/comments/thread/${threadId}`
     )
     return response.data.data!
   }
@@ -1933,6 +2954,28 @@ export class ApiService {
     return response.data.data!
   }
 
+  // Apply a comment's suggested change to its file and resolve the thread
+  async applyCommentSuggestion(commentId: number): Promise<{
+    comment_id: number
+    thread_id: string
+    file_id: number
+    file_version: number
+    version_id: number
+    content: string
+    resolved_at: string
+  }> {
+    const response = await this.client.post<ApiResponse<{
+      comment_id: number
+      thread_id: string
+      file_id: number
+      file_version: number
+      version_id: number
+      content: string
+      resolved_at: string
+    }>>(`/comments/${commentId}/apply`)
+    return response.data.data!
+  }
+
   // Add reaction to a comment
   async addCommentReaction(commentId: number, emoji: string): Promise<Record<string, number[]>> {
     const response = await this.client.post<ApiResponse<Record<string, number[]>>>(
@@ -1956,7 +2999,7 @@ export class ApiService {
   async saveAPIKey(
     provider: string,
     apiKey: string,
-    options?: { model_preference?: string }
+    options?: { model_preference?: string; capability_models?: Record<string, string> }
   ): Promise<{
     success: boolean
     message: string
@@ -1966,6 +3009,7 @@ export class ApiService {
       provider,
       api_key: apiKey,
       model_preference: options?.model_preference || '',
+      capability_models: options?.capability_models,
     })
     return response.data
   }
@@ -1975,6 +3019,7 @@ export class ApiService {
     data: Array<{
       provider: string
       model_preference: string
+      capability_models?: Record<string, string>
       is_active: boolean
       is_valid: boolean
       last_used?: string
@@ -2003,8 +3048,8 @@ export class ApiService {
 
   async updateAPIKeySettings(
     provider: string,
-    settings: { is_active?: boolean; model_preference?: string }
-  ): Promise<{ success: boolean; message: string }> {
+    settings: { is_active?: boolean; model_preference?: string; capability_models?: Record<string, string> }
+  ): Promise<{ success: boolean; message: string; capability_models?: Record<string, string> | null }> {
     const response = await this.client.patch(`/byok/keys/${provider}`, settings)
     return response.data
   }
@@ -2050,6 +3095,15 @@ export class ApiService {
       cost_tier: string
       description: string
     }>>
+    catalog?: Record<string, Array<{
+      id: string
+      name: string
+      speed: string
+      cost_tier: string
+      description: string
+      cost: { input_per_1m_usd: number; output_per_1m_usd: number; relative: number }
+    }>>
+    scopes?: { capabilities: string[]; build_roles: string[] }
   }> {
     const response = await this.client.get('/byok/models')
     return response.data
@@ -2346,6 +3400,25 @@ export class ApiService {
     return response.data.logs || []
   }
 
+  // SLSA provenance attestation (in-toto statement in a DSSE envelope)
+  async getDeploymentProvenance(deploymentId: string): Promise<{
+    success: boolean
+    provenance: {
+      deployment_id: string
+      source_build_id?: string
+      statement: Record<string, any>
+      envelope: { payloadType: string; payload: string; signatures: { keyid?: string; sig: string }[] }
+      created_at: string
+    }
+    signed: boolean
+    verified: boolean
+    public_key?: string
+    key_id?: string
+  }> {
+    const response = await this.client.get(`/deploy/${deploymentId}/provenance`)
+    return response.data
+  }
+
   async cancelExternalDeployment(deploymentId: string): Promise<void> {
     await this.client.delete(`/deploy/${deploymentId}`)
   }
@@ -2369,6 +3442,18 @@ export class ApiService {
     return response.data
   }
 
+  // Projected monthly hosting cost on native hosting and each provider
+  async estimateDeploymentCost(
+    projectId: number,
+    options: { monthlyViews?: number; alwaysOn?: boolean } = {}
+  ): Promise<DeploymentCostEstimate> {
+    const response = await this.client.get<{ success: boolean; estimate: DeploymentCostEstimate }>(
+      `/deploy/projects/${projectId}/estimate`,
+      { params: { monthly_views: options.monthlyViews, always_on: options.alwaysOn ? 'true' : undefined } }
+    )
+    return response.data.estimate
+  }
+
   // Get always-on status for a deployment
   async getAlwaysOnStatus(projectId: number, deploymentId: string): Promise<AlwaysOnStatus> {
     const response = await this.client.get<{ success: boolean; status: AlwaysOnStatus }>(
@@ -2443,6 +3528,55 @@ export class ApiService {
     await this.client.post(`/projects/${projectId}/deployments/${deploymentId}/restart`)
   }
 
+  // Set the endpoint restarts probe before moving traffic to a new container
+  async updateDeploymentHealthCheck(
+    projectId: number,
+    deploymentId: string,
+    healthCheck: Partial<DeploymentHealthCheck>
+  ): Promise<DeploymentHealthCheck> {
+    const response = await this.client.put<{ success: boolean; health_check: DeploymentHealthCheck }>(
+      `/projects/${projectId}/deployments/${deploymentId}/health-check`,
+      healthCheck
+    )
+    return response.data.health_check
+  }
+
+  // Restart history and other lifecycle events, newest first
+  async getDeploymentEvents(
+    projectId: number,
+    deploymentId: string,
+    options: { type?: 'restart' | 'crash_loop' | 'scale'; limit?: number } = {}
+  ): Promise<DeploymentEventsResponse> {
+    const response = await this.client.get<DeploymentEventsResponse & { success: boolean }>(
+      `/projects/${projectId}/deployments/${deploymentId}/events`,
+      { params: options }
+    )
+    return response.data
+  }
+
+  // Autoscaling policy, current load, the last hour of load and scale events
+  async getDeploymentAutoscale(projectId: number, deploymentId: string): Promise<DeploymentAutoscaleStatus> {
+    const response = await this.client.get<DeploymentAutoscaleStatus & { success: boolean }>(
+      `/projects/${projectId}/deployments/${deploymentId}/autoscale`
+    )
+    return response.data
+  }
+
+  // Set an always-on deployment's instance bounds and scaling targets
+  async updateDeploymentAutoscale(
+    projectId: number,
+    deploymentId: string,
+    config: Partial<AutoscaleConfig> & { enabled: boolean }
+  ): Promise<{ autoscale: AutoscaleConfig; current_instances: number; plan_max: number }> {
+    const response = await this.client.put<{
+      success: boolean
+      autoscale: AutoscaleConfig
+      current_instances: number
+      plan_max: number
+    }>(`/projects/${projectId}/deployments/${deploymentId}/autoscale`, config)
+    return response.data
+  }
+
   // Get deployment metrics
   async getDeploymentMetrics(projectId: number, deploymentId: string): Promise<DeploymentMetrics> {
     const response = await this.client.get<{ success: boolean; metrics: DeploymentMetrics }>(
@@ -2555,6 +3689,56 @@ export class ApiService {
     })
   }
 
+  /**
+   * Report what the user did with a shown completion. Partial accepts send
+   * how many characters were kept.
+   */
+  async recordCompletionEvent(
+    completionId: string,
+    outcome: CompletionOutcome,
+    acceptedChars?: number
+  ): Promise<void> {
+    await this.client.post('/completions/events', {
+      completion_id: completionId,
+      outcome,
+      accepted_chars: acceptedChars,
+    })
+  }
+
+  /**
+   * Get the user's completion acceptance rates per model and language
+   */
+  async getCompletionAnalytics(days?: number): Promise<CompletionAnalytics> {
+    const response = await this.client.get<{
+      success: boolean
+      data: CompletionAnalytics
+    }>('/completions/analytics', { params: days ? { days } : undefined })
+    return response.data.data
+  }
+
+  /**
+   * Get the user's completion routing settings and fast model latencies
+   */
+  async getCompletionSettings(): Promise<{ settings: CompletionSettings; fastModels: FastModelStatus[] }> {
+    const response = await this.client.get<{
+      success: boolean
+      data: CompletionSettings
+      fast_models: FastModelStatus[] | null
+    }>('/completions/settings')
+    return { settings: response.data.data, fastModels: response.data.fast_models || [] }
+  }
+
+  /**
+   * Update the completion mode, fast model or latency target
+   */
+  async updateCompletionSettings(settings: Partial<CompletionSettings>): Promise<CompletionSettings> {
+    const response = await this.client.put<{
+      success: boolean
+      data: CompletionSettings
+    }>('/completions/settings', settings)
+    return response.data.data
+  }
+
   /**
    * Get completion statistics (admin only)
    */
@@ -2750,819 +3934,2911 @@ export class ApiService {
     )
   }
 
-  // ========== HOSTING SYSTEM ENDPOINTS (*.apex.app) ==========
+  // ========== NOTEBOOK ENDPOINTS ==========
 
-  /**
-   * Get hosting status for a project
-   * Returns current deployment state, URL, and metrics
-   */
-  async getHostingStatus(projectId: number): Promise<HostingStatus> {
-    const response = await this.client.get<{ success: boolean; data: HostingStatus }>(
-      `/hosting/${projectId}/status`
-    )
-    return response.data.data
+  // Start a persistent notebook kernel for a project
+  async startNotebookKernel(data: {
+    project_id: number
+    language?: string
+  }): Promise<{ kernel: NotebookKernel; stream_url: string }> {
+    const response = await this.client.post('/notebooks/kernels', data)
+    return response.data
   }
 
-  /**
-   * Quick deploy a project to *.apex.app
-   * Auto-detects build configuration if not provided
-   */
-  async quickDeploy(
-    projectId: number,
-    config?: QuickDeployConfig
-  ): Promise<{
-    success: boolean
-    message: string
-    deployment: NativeDeployment
-    websocket_url: string
-  }> {
-    const response = await this.client.post(`/hosting/${projectId}/deploy`, config || {})
+  // List the current user's running kernels
+  async listNotebookKernels(): Promise<NotebookKernel[]> {
+    const response = await this.client.get('/notebooks/kernels')
+    return response.data.kernels
+  }
+
+  // Run a cell; pass path + cell_id to save outputs into the .ipynb file
+  async executeNotebookCell(
+    kernelId: string,
+    data: { code?: string; cell_id?: string; path?: string }
+  ): Promise<{ result: NotebookExecutionResult; saved: boolean; save_error?: string }> {
+    const response = await this.client.post(`/notebooks/kernels/${kernelId}/execute`, data)
     return response.data
   }
 
-  /**
-   * Undeploy/stop a project's hosting
-   */
-  async undeploy(projectId: number): Promise<{ success: boolean; message: string }> {
-    const response = await this.client.delete(`/hosting/${projectId}/undeploy`)
+  // Interrupt the running cell, keeping kernel state
+  async interruptNotebookKernel(kernelId: string): Promise<{ message: string }> {
+    const response = await this.client.post(`/notebooks/kernels/${kernelId}/interrupt`)
     return response.data
   }
 
-  /**
-   * Get deployment logs for a project
-   */
-  async getHostingLogs(
-    projectId: number,
-    options?: { limit?: number; level?: string; since?: string }
-  ): Promise<DeploymentLog[]> {
-    const params = new URLSearchParams()
-    if (options?.limit) params.append('limit', options.limit.toString())
-    if (options?.level) params.append('level', options.level)
-    if (options?.since) params.append('since', options.since)
-    const query = params.toString() ? `?${params.toString()}` : ''
+  // Restart the kernel, clearing its state
+  async restartNotebookKernel(kernelId: string): Promise<{ kernel: NotebookKernel }> {
+    const response = await this.client.post(`/notebooks/kernels/${kernelId}/restart`)
+    return response.data
+  }
 
-    const response = await this.client.get<{ success: boolean; data: { logs: DeploymentLog[] } }>(
-      `/hosting/${projectId}/logs${query}`
-    )
-    return response.data.data?.logs || []
+  // Shut down a kernel
+  async shutdownNotebookKernel(kernelId: string): Promise<{ message: string }> {
+    const response = await this.client.delete(`/notebooks/kernels/${kernelId}`)
+    return response.data
   }
 
-  /**
-   * Get the deployment URL for a project
-   */
-  async getDeploymentUrl(projectId: number): Promise<{
-    url: string
-    subdomain: string
-    status: string
-    ssl_enabled: boolean
+  // Load a project .ipynb file
+  async getNotebook(projectId: number, path: string): Promise<{
+    file_id: number
+    path: string
+    language: string
+    notebook: NotebookDocument
   }> {
-    const response = await this.client.get<{
-      success: boolean
-      data: { url: string; subdomain: string; status: string; ssl_enabled: boolean }
-    }>(`/hosting/${projectId}/url`)
-    return response.data.data
+    const response = await this.client.get(`/notebooks/projects/${projectId}/file`, { params: { path } })
+    return response.data
   }
 
-  /**
-   * Check if a subdomain is available
-   */
-  async checkSubdomainAvailability(subdomain: string): Promise<{
-    available: boolean
-    subdomain: string
-    suggestion?: string
+  // Save a notebook as a project file (created when missing)
+  async saveNotebook(projectId: number, path: string, notebook: NotebookDocument): Promise<{
+    file_id: number
+    path: string
+    notebook: NotebookDocument
   }> {
-    const response = await this.client.get<{
-      success: boolean
-      data: { available: boolean; subdomain: string; suggestion?: string }
-    }>(`/hosting/check-subdomain?subdomain=${encodeURIComponent(subdomain)}`)
-    return response.data.data
+    const response = await this.client.put(`/notebooks/projects/${projectId}/file`, { path, notebook })
+    return response.data
   }
 
-  // ========== CUSTOM DOMAIN MANAGEMENT ==========
+  // Get WebSocket URL for notebook kernel events
+  getNotebookWebSocketUrl(kernelId: string): string {
+    return buildAuthenticatedWebSocketUrl(
+      `${resolveWebSocketBaseUrl(this.baseURL, window.location.host)}/notebook/${encodeURIComponent(kernelId)}`
+    )
+  }
 
-  /**
-   * Get all custom domains for a project
-   */
-  async getCustomDomains(projectId: number): Promise<CustomDomain[]> {
-    const response = await this.client.get<{
-      success: boolean
-      data: { domains: CustomDomain[] }
-    }>(`/hosting/${projectId}/domains`)
-    return response.data.data?.domains || []
+  // ========== SCHEDULED TASKS (cron runs of a project command) ==========
+
+  async listSchedules(projectId: number): Promise<ScheduledTask[]> {
+    const response = await this.client.get(`/projects/${projectId}/schedules`)
+    return response.data.data.schedules
   }
 
-  /**
-   * Add a custom domain to a project
-   */
-  async addCustomDomain(
-    projectId: number,
-    domain: string
-  ): Promise<{
-    domain: CustomDomain
-    verification_instructions: string
-  }> {
-    const response = await this.client.post<{
-      success: boolean
-      message: string
-      data: { domain: CustomDomain; verification_instructions: string }
-    }>(`/hosting/${projectId}/domains`, { domain })
-    return response.data.data
+  async createSchedule(projectId: number, data: ScheduledTaskInput): Promise<ScheduledTask> {
+    const response = await this.client.post(`/projects/${projectId}/schedules`, data)
+    return response.data.data.schedule
   }
 
-  /**
-   * Verify a custom domain's DNS configuration
-   */
-  async verifyCustomDomain(
-    projectId: number,
-    domainId: number
-  ): Promise<{
-    verified: boolean
-    domain: CustomDomain
-    error?: string
-  }> {
-    const response = await this.client.post<{
-      success: boolean
-      data: { verified: boolean; domain: CustomDomain; error?: string }
-    }>(`/hosting/${projectId}/domains/${domainId}/verify`)
-    return response.data.data
+  // Edit a task or toggle it with { enabled }
+  async updateSchedule(id: number, data: Partial<ScheduledTaskInput>): Promise<ScheduledTask> {
+    const response = await this.client.patch(`/schedules/${id}`, data)
+    return response.data.data.schedule
   }
 
-  /**
-   * Delete a custom domain from a project
-   */
-  async deleteCustomDomain(projectId: number, domainId: number): Promise<void> {
-    await this.client.delete(`/hosting/${projectId}/domains/${domainId}`)
+  async deleteSchedule(id: number): Promise<void> {
+    await this.client.delete(`/schedules/${id}`)
   }
 
-  /**
-   * Get WebSocket URL for deployment logs streaming
-   */
-  getDeploymentLogsWebSocketUrl(deploymentId: string): string {
-    return buildAuthenticatedWebSocketUrl(
-      `${resolveWebSocketBaseUrl(this.baseURL, window.location.host)}/deploy/${encodeURIComponent(deploymentId)}`
-    )
+  // Run a task now; poll getScheduleRun for the outcome
+  async runSchedule(id: number): Promise<ScheduledTaskRun> {
+    const response = await this.client.post(`/schedules/${id}/run`)
+    return response.data.data.run
   }
 
-  // ========== PLAN USAGE QUOTA ENDPOINTS ==========
+  // Run history, newest first (logs omitted)
+  async listScheduleRuns(id: number, limit?: number): Promise<ScheduledTaskRun[]> {
+    const response = await this.client.get(`/schedules/${id}/runs`, { params: { limit } })
+    return response.data.data.runs
+  }
 
-  /**
-   * Get current usage and limits for the authenticated user
-   * Returns usage across all tracked dimensions: projects, storage, AI requests, execution time
-   */
-  async getCurrentUsage(): Promise<CurrentUsageData> {
-    const response = await this.client.get<{ success: boolean; data: CurrentUsageData }>(
-      '/usage/current'
-    )
-    return response.data.data
+  async getScheduleRun(id: number, runId: number): Promise<ScheduledTaskRun> {
+    const response = await this.client.get(`/schedules/${id}/runs/${runId}`)
+    return response.data.data.run
   }
 
-  /**
-   * Get historical usage data for charts and trend analysis
-   */
-  async getUsageHistory(days: number = 30): Promise<UsageHistoryData> {
-    const response = await this.client.get<{ success: boolean; data: UsageHistoryData }>(
-      `/usage/history?days=${days}`
-    )
+  // ========== DEPENDENCY UPDATE AGENT ==========
+
+  // Policy plus recent runs (change sets and logs omitted)
+  async getDependencyUpdates(projectId: number, limit?: number): Promise<{ policy: DependencyUpdatePolicy; runs: DependencyUpdateRun[] }> {
+    const response = await this.client.get(`/projects/${projectId}/dependency-updates`, { params: { limit } })
     return response.data.data
   }
 
-  /**
-   * Get plan limits for the current user and all available plans
-   * Useful for showing upgrade comparison
-   */
-  async getUsageLimits(): Promise<UsageLimitsData> {
-    const response = await this.client.get<{ success: boolean; data: UsageLimitsData }>(
-      '/usage/limits'
-    )
-    return response.data.data
-  }
-
-  /**
-   * Force refresh the usage cache (useful after major operations)
-   */
-  async refreshUsageCache(): Promise<{ message: string }> {
-    const response = await this.client.post<{ success: boolean; message: string }>(
-      '/usage/refresh'
-    )
-    return { message: response.data.message }
+  async updateDependencyUpdatePolicy(projectId: number, data: DependencyUpdatePolicyInput): Promise<DependencyUpdatePolicy> {
+    const response = await this.client.put(`/projects/${projectId}/dependency-updates`, data)
+    return response.data.data.policy
   }
 
-  // ========== PROJECT ASSET ENDPOINTS ==========
-
-  async listAssets(projectId: number): Promise<import('@/types').ProjectAsset[]> {
-    const response = await this.client.get<{
-      success: boolean
-      assets: import('@/types').ProjectAsset[]
-      count: number
-    }>(`/projects/${projectId}/assets`)
-    return response.data.assets || []
+  // Check for updates now; poll getDependencyUpdateRun for the outcome
+  async runDependencyUpdates(projectId: number): Promise<DependencyUpdateRun> {
+    const response = await this.client.post(`/projects/${projectId}/dependency-updates/run`)
+    return response.data.data.run
   }
 
-  async uploadAsset(projectId: number, nativeFile: Blob): Promise<{
-    asset: import('@/types').ProjectAsset
-    message: string
-  }> {
-    const formData = new FormData()
-    formData.append('file', nativeFile)
-    const response = await this.client.post<{
-      success: boolean
-      asset: import('@/types').ProjectAsset
-      message: string
-    }>(`/projects/${projectId}/assets`, formData, {
-      headers: { 'Content-Type': 'multipart/form-data' },
-    })
-    return { asset: response.data.asset, message: response.data.message }
+  async getDependencyUpdateRun(projectId: number, runId: number): Promise<DependencyUpdateRun> {
+    const response = await this.client.get(`/projects/${projectId}/dependency-updates/runs/${runId}`)
+    return response.data.data.run
   }
 
-  async deleteAsset(projectId: number, assetId: number): Promise<void> {
-    await this.client.delete(`/projects/${projectId}/assets/${assetId}`)
+  // Write a verified change set into the project (409 when the manifests changed since)
+  async applyDependencyUpdateRun(projectId: number, runId: number): Promise<DependencyUpdateRun> {
+    const response = await this.client.post(`/projects/${projectId}/dependency-updates/runs/${runId}/apply`)
+    return response.data.data.run
   }
 
-  // ── Billing: credit purchase & balance ───────────────────────────────────
+  // ========== BUILD FAILURE INSIGHTS ==========
 
-  async purchaseCredits(params: {
-    amount_usd: number
-    success_url: string
-    cancel_url: string
-  }): Promise<{ success: boolean; data?: { session_id: string; checkout_url: string }; error?: string }> {
-    const response = await this.client.post('/billing/credits/purchase', params)
-    return response.data
+  // Top failure causes and flaky descriptions for the caller, or for an
+  // organization's members (requires projects:manage)
+  async getBuildFailureReport(params?: { days?: number; limit?: number; organization_id?: number }): Promise<BuildFailureReport> {
+    const response = await this.client.get('/build-insights/failures', { params })
+    return response.data.data
   }
 
-  async getCreditBalance(): Promise<{
-    success: boolean
-    data?: {
-      balance: number
-      has_unlimited: boolean
-      bypass_billing: boolean
-      available_packs: Array<{ amount_usd: number; credit_usd: number; label: string }>
-    }
-  }> {
-    const response = await this.client.get('/billing/credits/balance')
-    return response.data
+  // Platform-wide unless user_id or organization_id is given (admin only)
+  async getAdminBuildFailureReport(params?: { days?: number; limit?: number; user_id?: number; organization_id?: number }): Promise<BuildFailureReport> {
+    const response = await this.client.get('/admin/build-insights/failures', { params })
+    return response.data.data
   }
 
-  async getBuildSpend(buildId: string): Promise<{
-    success: boolean
-    data?: {
-      total_spend: number
-      events: Array<Record<string, unknown>>
-    }
+  // ========== SUPPORT DIAGNOSTICS BUNDLES ==========
+
+  // Redacted diagnostics for a finished build or deployment; the token is
+  // shown once and downloads the bundle without account access
+  async createSupportBundle(data: { kind: SupportBundleKind; target_id: string; expires_in_days?: number }): Promise<{
+    bundle: SupportBundle
+    token: string
+    share_path: string
   }> {
-    const response = await this.client.get(`/spend/build/${encodeURIComponent(buildId)}`)
-    return response.data
+    const response = await this.client.post('/support-bundles', data)
+    return response.data.data
   }
 
-  async getSubscription(): Promise<{
-    success: boolean
-    data?: {
-      plan_type: string
-      plan_name: string
-      status: string
-      current_period_end: string
-      cancel_at_period_end?: boolean
-      cancel_at?: string
-      monthly_credits_usd?: number
-    }
-  }> {
-    const response = await this.client.get('/billing/subscription')
-    return response.data
+  async listSupportBundles(): Promise<SupportBundle[]> {
+    const response = await this.client.get('/support-bundles')
+    return response.data.data.bundles
   }
 
-  async getPlans(): Promise<{
-    success: boolean
-    data?: {
-      plans: Array<{
-        type: string
-        name: string
-        monthly_price_cents: number
-        monthly_price_id: string
-        monthly_credits_usd: number
-        is_popular: boolean
-        features: string[]
-      }>
-      self_serve_ready?: boolean
-      stripe_configured?: boolean
-      webhook_configured?: boolean
-      required_price_ids_configured?: boolean
-    }
-  }> {
-    const response = await this.client.get('/billing/plans')
+  async downloadSupportBundle(id: number): Promise<Blob> {
+    const response = await this.client.get(`/support-bundles/${id}/download`, { responseType: 'blob' })
     return response.data
   }
 
-  async createCheckoutSession(params: {
-    price_id: string
-    success_url?: string
-    cancel_url?: string
-    apply_promo?: boolean
-  }): Promise<{ success: boolean; data?: { session_id: string; checkout_url: string }; error?: string }> {
-    const response = await this.client.post('/billing/checkout', params)
-    return response.data
+  // Disable the bundle's support token
+  async revokeSupportBundle(id: number): Promise<void> {
+    await this.client.delete(`/support-bundles/${id}`)
   }
 
-  async createBillingPortalSession(return_url: string): Promise<{
-    success: boolean
-    data?: { portal_url: string }
-    error?: string
-  }> {
-    const response = await this.client.post('/billing/portal', { return_url })
+  // ========== PROJECT TEMPLATES ==========
+
+  // The template plus the prompt schema for its variables
+  async getTemplate(id: string): Promise<{ template: TemplateDefinition; variables: TemplateVariablePrompt[] }> {
+    const response = await this.client.get(`/templates/${id}`)
     return response.data
   }
 
-  async changePlan(params: {
-    plan_type: string
-    billing_cycle: 'monthly' | 'annual'
+  // Secret variables become project secrets; blank ones are generated or
+  // left as placeholders. Invalid values fail with 400 and per-field errors.
+  async createProjectFromTemplate(data: {
+    template_id: string
+    project_name: string
+    description?: string
+    variables?: Record<string, string>
   }): Promise<{
-    success: boolean
-    data?: {
-      plan_type: string
-      billing_cycle: string
-      status: string
-      current_period_end: string
-    }
-    error?: string
+    project: Project
+    files_count: number
+    template: string
+    secrets_created: string[]
+    secret_placeholders: string[]
   }> {
-    const response = await this.client.post('/billing/change-plan', params)
+    const response = await this.client.post('/templates/create-project', data)
     return response.data
   }
 
-  async getInvoices(): Promise<{
-    success: boolean
-    data?: {
-      invoices: Array<{
-        id: string
-        amount_paid: number
-        currency: string
-        status: string
-        created: number
-        hosted_invoice_url: string
-        description: string
-      }>
-    }
+  // ========== PROJECT ACTIVITY FEED ==========
+
+  // Edits, builds, deployments, comments and joins, newest first. New events
+  // arrive live as "activity" messages on the collaboration WebSocket.
+  async getProjectActivity(projectId: number, params?: { kind?: ProjectActivityKind[]; limit?: number; cursor?: string }): Promise<{
+    activities: ProjectActivity[]
+    page_info: PageInfo
   }> {
-    const response = await this.client.get('/billing/invoices')
-    return response.data
+    const response = await this.client.get(`/projects/${projectId}/activity`, {
+      params: { limit: params?.limit, cursor: params?.cursor, kind: params?.kind?.join(',') || undefined },
+    })
+    return { activities: response.data.data.activities, page_info: response.data.page_info }
   }
 
-  // ---------------------------------------------------------------------------
-  // Enterprise API
-  // ---------------------------------------------------------------------------
+  // ========== PROJECT RESTORE POINTS (whole-tree snapshots) ==========
 
-  async getOrganizations(): Promise<{
-    success: boolean
-    organizations?: Organization[]
-    error?: string
-  }> {
-    const response = await this.client.get('/enterprise/organizations')
-    return response.data
+  async getRestorePoints(projectId: number, params?: { limit?: number; tagged?: boolean }): Promise<RestorePoint[]> {
+    const response = await this.client.get(`/projects/${projectId}/restore-points`, {
+      params: { limit: params?.limit, tagged: params?.tagged || undefined },
+    })
+    return response.data.data.restore_points
   }
 
-  async getOrganization(id: number): Promise<{
-    success: boolean
-    organization?: Organization
-    error?: string
-  }> {
-    const response = await this.client.get(`/enterprise/organizations/${id}`)
-    return response.data
+  async createRestorePoint(projectId: number, label?: string): Promise<RestorePoint> {
+    const response = await this.client.post(`/projects/${projectId}/restore-points`, { label })
+    return response.data.data.restore_point
   }
 
-  async configureSSO(id: number, config: SSOConfigRequest): Promise<{
-    success: boolean
-    message?: string
-    error?: string
-  }> {
-    const response = await this.client.post(`/enterprise/organizations/${id}/sso`, config)
-    return response.data
+  // Omit `to` to compare against the project's current files
+  async diffRestorePoints(projectId: number, from: number, to?: number, directory?: string): Promise<RestorePointDiff> {
+    const response = await this.client.get(`/projects/${projectId}/restore-points/diff`, {
+      params: { from, to: to ?? 'current', directory: directory || undefined },
+    })
+    return response.data.data
   }
 
-  async getAuditLogs(id: number, params?: {
-    action?: string
-    category?: string
-    page?: number
-    page_size?: number
-  }): Promise<{
-    success: boolean
-    audit_logs?: AuditLog[]
-    total?: number
-    page?: number
-    page_size?: number
-    error?: string
-  }> {
-    const response = await this.client.get(`/enterprise/organizations/${id}/audit-logs`, { params })
-    return response.data
+  // Omit `directory` to restore the whole project
+  async restoreToPoint(projectId: number, pointId: number, directory?: string): Promise<RestorePointResult> {
+    const response = await this.client.post(`/projects/${projectId}/restore-points/${pointId}/restore`, {
+      directory: directory || undefined,
+    })
+    return response.data.data
   }
 
-  async getRoles(id: number): Promise<{
-    success: boolean
-    roles?: Role[]
-    error?: string
-  }> {
-    const response = await this.client.get(`/enterprise/organizations/${id}/roles`)
-    return response.data
+  // An empty tag removes it; new tags are protected unless `protected` is false
+  async tagRestorePoint(projectId: number, pointId: number, tag: string, isProtected?: boolean): Promise<RestorePoint> {
+    const response = await this.client.put(`/projects/${projectId}/restore-points/${pointId}/tag`, {
+      tag,
+      protected: isProtected,
+    })
+    return response.data.data.restore_point
   }
-}
 
-export interface TerminalSessionResponse {
-  session_id: string
-  project_id: number
-  work_dir: string
-  shell: string
-  name: string
-  rows: number
-  cols: number
-  created_at: string
-  ws_endpoint: string
-}
+  async deleteRestorePoint(projectId: number, pointId: number): Promise<void> {
+    await this.client.delete(`/projects/${projectId}/restore-points/${pointId}`)
+  }
 
-export interface TerminalSessionCreateResponse {
-  success: boolean
-  data: TerminalSessionResponse
-}
+  // ========== ONBOARDING (guided setup checklist) ==========
 
-export interface TerminalSessionInfo {
-  id: string
-  project_id: number
-  user_id: number
-  work_dir: string
-  shell: string
-  shell_name?: string
-  name: string
-  created_at: string
-  last_active: string
-  is_active: boolean
-  rows: number
-  cols: number
-  ws_endpoint: string
-}
+  // Checklist progress; the first call provisions the sample project
+  async getOnboarding(): Promise<OnboardingProgress> {
+    const response = await this.client.get('/onboarding')
+    return response.data.data
+  }
 
-export interface AvailableShell {
-  name: string
-  path: string
-}
+  // Tick off a manual step
+  async completeOnboardingStep(key: string): Promise<OnboardingProgress> {
+    const response = await this.client.post(`/onboarding/steps/${encodeURIComponent(key)}/complete`)
+    return response.data.data
+  }
 
-// ---------------------------------------------------------------------------
-// Always-On Deployment types (Replit parity feature)
-// ---------------------------------------------------------------------------
+  async dismissOnboarding(): Promise<void> {
+    await this.client.post('/onboarding/dismiss')
+  }
 
-export type ExternalDeploymentProviderId =
-  | 'vercel'
-  | 'netlify'
-  | 'render'
-  | 'railway'
-  | 'cloudflare_pages'
+  async getOrganizationOnboardingChecklist(orgId: number): Promise<{ checklist: OnboardingChecklist; customized: boolean }> {
+    const response = await this.client.get(`/enterprise/organizations/${orgId}/onboarding-checklist`)
+    return response.data.data
+  }
 
-export interface ExternalDeploymentProvider {
-  id: ExternalDeploymentProviderId
-  name: string
-  description: string
+  async updateOrganizationOnboardingChecklist(orgId: number, data: OnboardingChecklistInput): Promise<{ checklist: OnboardingChecklist; customized: boolean }> {
+    const response = await this.client.put(`/enterprise/organizations/${orgId}/onboarding-checklist`, data)
+    return response.data.data
+  }
+
+  // Restore the default checklist for an organization
+  async resetOrganizationOnboardingChecklist(orgId: number): Promise<void> {
+    await this.client.delete(`/enterprise/organizations/${orgId}/onboarding-checklist`)
+  }
+
+  // ========== REFERRALS ==========
+
+  // Referral link with clicks, signups and earned credits
+  async getReferralDashboard(): Promise<ReferralDashboardResponse> {
+    const response = await this.client.get('/referrals')
+    return response.data.data
+  }
+
+  // ========== ABUSE ==========
+
+  // Whether the current user may run code, and their open abuse case
+  async getAbuseStatus(): Promise<AbuseStatus> {
+    const response = await this.client.get('/abuse/status')
+    return response.data.data
+  }
+
+  // Ask for review of throttled or suspended execution access
+  async appealAbuseCase(message: string): Promise<AbuseCase> {
+    const response = await this.client.post('/abuse/appeal', { message })
+    return response.data.data
+  }
+
+  // ========== MANAGEMENT TOKENS ==========
+
+  // Personal tokens for the management API / Terraform provider
+  async listManagementTokens(): Promise<ManagementToken[]> {
+    const response = await this.client.get('/user/management-tokens')
+    return response.data.data.tokens
+  }
+
+  // Create a token; the secret is only returned once
+  async createManagementToken(name: string, expiresInDays?: number): Promise<CreatedManagementToken> {
+    const response = await this.client.post('/user/management-tokens', {
+      name,
+      expires_in_days: expiresInDays ?? 0,
+    })
+    return response.data.data
+  }
+
+  async revokeManagementToken(id: number): Promise<void> {
+    await this.client.delete(`/user/management-tokens/${id}`)
+  }
+
+  // ========== DEPLOYMENT ENVIRONMENTS ==========
+
+  // Environments with the release live in each, plus available targets
+  async getProjectEnvironments(projectId: number): Promise<{ environments: ProjectEnvironment[]; targets: string[] }> {
+    const response = await this.client.get(`/projects/${projectId}/environments`)
+    return response.data.data
+  }
+
+  // Create or replace an environment; omit env_vars to keep the current ones
+  async applyProjectEnvironment(projectId: number, env: EnvironmentName, spec: EnvironmentSpec): Promise<ProjectEnvironment> {
+    const response = await this.client.put(`/projects/${projectId}/environments/${env}`, spec)
+    return response.data.data
+  }
+
+  async deleteProjectEnvironment(projectId: number, env: EnvironmentName): Promise<void> {
+    await this.client.delete(`/projects/${projectId}/environments/${env}`)
+  }
+
+  // Snapshot the project's files and deploy them to the environment
+  async deployEnvironment(projectId: number, env: EnvironmentName): Promise<EnvironmentRelease> {
+    const response = await this.client.post(`/projects/${projectId}/environments/${env}/deploy`)
+    return response.data.data
+  }
+
+  // Deploy the artifact live in `from` (default: the previous environment)
+  async promoteEnvironment(projectId: number, env: EnvironmentName, from?: EnvironmentName): Promise<EnvironmentRelease> {
+    const response = await this.client.post(`/projects/${projectId}/environments/${env}/promote`, from ? { from } : {})
+    return response.data.data
+  }
+
+  async getEnvironmentReleases(projectId: number, env: EnvironmentName, limit?: number): Promise<EnvironmentRelease[]> {
+    const response = await this.client.get(`/projects/${projectId}/environments/${env}/releases`, {
+      params: limit ? { limit } : undefined,
+    })
+    return response.data.data
+  }
+
+  // ========== DOCKERFILE GENERATION ==========
+
+  // Preview the generated Dockerfile and the latest verification build
+  async getDockerize(projectId: number): Promise<{
+    preview: DockerfileResult | null
+    preview_error: string
+    run: DockerizeRun | null
+    verify_available: boolean
+  }> {
+    const response = await this.client.get(`/projects/${projectId}/dockerize`)
+    return response.data
+  }
+
+  // Generate a Dockerfile and build it; it is stored only if the build succeeds
+  async dockerizeProject(projectId: number, overwrite = false): Promise<DockerizeRun> {
+    const response = await this.client.post(`/projects/${projectId}/dockerize`, { overwrite })
+    return response.data.run
+  }
+
+  // ========== ENVIRONMENT LOCK ==========
+
+  // Compare the project against its apex.lock, writing one if it has none
+  async getEnvironmentLock(projectId: number): Promise<EnvironmentLockCheck> {
+    const response = await this.client.get(`/projects/${projectId}/environment-lock`)
+    return response.data.environment_lock
+  }
+
+  // Replace apex.lock with the project's current environment, accepting drift
+  async regenerateEnvironmentLock(projectId: number): Promise<EnvironmentLockCheck> {
+    const response = await this.client.post(`/projects/${projectId}/environment-lock`)
+    return response.data.environment_lock
+  }
+
+  // ========== ENVIRONMENT VARIABLE CHECK ==========
+
+  // List variables the project's code reads that its environments lack
+  async getEnvVarCheck(projectId: number): Promise<EnvVarCheck> {
+    const response = await this.client.get(`/projects/${projectId}/env-check`)
+    return response.data.env_var_check
+  }
+
+  // Create empty project secrets for missing variables (all suggested ones when names is omitted)
+  async createEnvPlaceholders(projectId: number, names?: string[]): Promise<{
+    created: EnvPlaceholderSecret[]
+    env_var_check: EnvVarCheck
+  }> {
+    const response = await this.client.post(`/projects/${projectId}/env-check/placeholders`, { names: names || [] })
+    return { created: response.data.created, env_var_check: response.data.env_var_check }
+  }
+
+  // ========== PROJECT SERVICE TOKENS ==========
+
+  // Whether the project's scheduled runs and hosted apps get a service token
+  async getProjectServiceToken(projectId: number): Promise<ProjectServiceTokenStatus> {
+    const response = await this.client.get(`/projects/${projectId}/service-token`)
+    return response.data
+  }
+
+  async enableProjectServiceToken(projectId: number): Promise<ProjectServiceTokenStatus> {
+    const response = await this.client.post(`/projects/${projectId}/service-token`)
+    return response.data
+  }
+
+  // Revokes the current and previous token values at once
+  async disableProjectServiceToken(projectId: number): Promise<void> {
+    await this.client.delete(`/projects/${projectId}/service-token`)
+  }
+
+  // Rotate now; the replaced value keeps working for 24 hours
+  async rotateProjectServiceToken(projectId: number): Promise<ProjectServiceTokenStatus> {
+    const response = await this.client.post(`/projects/${projectId}/service-token/rotate`)
+    return response.data
+  }
+
+  async getProjectServiceTokenUsage(projectId: number, limit?: number): Promise<ProjectServiceTokenUsage[]> {
+    const response = await this.client.get(`/projects/${projectId}/service-token/usage`, {
+      params: limit ? { limit } : undefined,
+    })
+    return response.data.usage
+  }
+
+  // ========== HOSTING SYSTEM ENDPOINTS (*.apex.app) ==========
+
+  /**
+   * Get hosting status for a project
+   * Returns current deployment state, URL, and metrics
+   */
+  async getHostingStatus(projectId: number): Promise<HostingStatus> {
+    const response = await this.client.get<{ success: boolean; data: HostingStatus }>(
+      `/hosting/${projectId}/status`
+    )
+    return response.data.data
+  }
+
+  /**
+   * Quick deploy a project to *.apex.app
+   * Auto-detects build configuration if not provided
+   */
+  async quickDeploy(
+    projectId: number,
+    config?: QuickDeployConfig
+  ): Promise<{
+    success: boolean
+    message: string
+    deployment: NativeDeployment
+    websocket_url: string
+  }> {
+    const response = await this.client.post(`/hosting/${projectId}/deploy`, config || {})
+    return response.data
+  }
+
+  /**
+   * Undeploy/stop a project's hosting
+   */
+  async undeploy(projectId: number): Promise<{ success: boolean; message: string }> {
+    const response = await this.client.delete(`/hosting/${projectId}/undeploy`)
+    return response.data
+  }
+
+  /**
+   * Get deployment logs for a project
+   */
+  async getHostingLogs(
+    projectId: number,
+    options?: { limit?: number; level?: string; since?: string }
+  ): Promise<DeploymentLog[]> {
+    const params = new URLSearchParams()
+    if (options?.limit) params.append('limit', options.limit.toString())
+    if (options?.level) params.append('level', options.level)
+    if (options?.since) params.append('since', options.since)
+    const query = params.toString() ? `?${params.toString()}` : ''
+
+    const response = await this.client.get<{ success: boolean; data: { logs: DeploymentLog[] } }>(
+      `/hosting/${projectId}/logs${query}`
+    )
+    return response.data.data?.logs || []
+  }
+
+  /**
+   * Get the deployment URL for a project
+   */
+  async getDeploymentUrl(projectId: number): Promise<{
+    url: string
+    subdomain: string
+    status: string
+    ssl_enabled: boolean
+  }> {
+    const response = await this.client.get<{
+      success: boolean
+      data: { url: string; subdomain: string; status: string; ssl_enabled: boolean }
+    }>(`/hosting/${projectId}/url`)
+    return response.data.data
+  }
+
+  /**
+   * Check if a subdomain is available
+   */
+  async checkSubdomainAvailability(subdomain: string): Promise<{
+    available: boolean
+    subdomain: string
+    suggestion?: string
+  }> {
+    const response = await this.client.get<{
+      success: boolean
+      data: { available: boolean; subdomain: string; suggestion?: string }
+    }>(`/hosting/check-subdomain?subdomain=${encodeURIComponent(subdomain)}`)
+    return response.data.data
+  }
+
+  // ========== CUSTOM DOMAIN MANAGEMENT ==========
+
+  /**
+   * Get all custom domains for a project
+   */
+  async getCustomDomains(projectId: number): Promise<CustomDomain[]> {
+    const response = await this.client.get<{
+      success: boolean
+      data: { domains: CustomDomain[] }
+    }>(`/hosting/${projectId}/domains`)
+    return response.data.data?.domains || []
+  }
+
+  /**
+   * Add a custom domain to a project
+   */
+  async addCustomDomain(
+    projectId: number,
+    domain: string
+  ): Promise<{
+    domain: CustomDomain
+    verification_instructions: string
+  }> {
+    const response = await this.client.post<{
+      success: boolean
+      message: string
+      data: { domain: CustomDomain; verification_instructions: string }
+    }>(`/hosting/${projectId}/domains`, { domain })
+    return response.data.data
+  }
+
+  /**
+   * Verify a custom domain's DNS configuration
+   */
+  async verifyCustomDomain(
+    projectId: number,
+    domainId: number
+  ): Promise<{
+    verified: boolean
+    domain: CustomDomain
+    error?: string
+  }> {
+    const response = await this.client.post<{
+      success: boolean
+      data: { verified: boolean; domain: CustomDomain; error?: string }
+    }>(`/hosting/${projectId}/domains/${domainId}/verify`)
+    return response.data.data
+  }
+
+  /**
+   * Delete a custom domain from a project
+   */
+  async deleteCustomDomain(projectId: number, domainId: number): Promise<void> {
+    await this.client.delete(`/hosting/${projectId}/domains/${domainId}`)
+  }
+
+  // ========== BACKGROUND WORKERS ==========
+
+  /**
+   * List a project's background worker processes
+   */
+  async getWorkers(projectId: number): Promise<HostingWorker[]> {
+    const response = await this.client.get<{ success: boolean; workers: HostingWorker[] }>(
+      `/hosting/${projectId}/workers`
+    )
+    return response.data.workers || []
+  }
+
+  /**
+   * Add a worker process; `queue: true` provisions the project's managed Redis queue
+   */
+  async createWorker(projectId: number, config: HostingWorkerConfig): Promise<HostingWorker> {
+    const response = await this.client.post<{ success: boolean; worker: HostingWorker }>(
+      `/hosting/${projectId}/workers`,
+      config
+    )
+    return response.data.worker
+  }
+
+  /**
+   * Change a worker's command, replicas, limits or idle policy
+   */
+  async updateWorker(
+    projectId: number,
+    workerId: number,
+    update: Partial<Omit<HostingWorkerConfig, 'name' | 'queue'>>
+  ): Promise<HostingWorker> {
+    const response = await this.client.patch<{ success: boolean; worker: HostingWorker }>(
+      `/hosting/${projectId}/workers/${workerId}`,
+      update
+    )
+    return response.data.worker
+  }
+
+  /**
+   * Restart (or wake) a worker
+   */
+  async restartWorker(projectId: number, workerId: number): Promise<HostingWorker> {
+    const response = await this.client.post<{ success: boolean; worker: HostingWorker }>(
+      `/hosting/${projectId}/workers/${workerId}/restart`
+    )
+    return response.data.worker
+  }
+
+  async deleteWorker(projectId: number, workerId: number): Promise<void> {
+    await this.client.delete(`/hosting/${projectId}/workers/${workerId}`)
+  }
+
+  async getWorkerLogs(projectId: number, workerId: number, limit = 100): Promise<DeploymentLog[]> {
+    const response = await this.client.get<{ success: boolean; logs: DeploymentLog[] }>(
+      `/hosting/${projectId}/workers/${workerId}/logs`,
+      { params: { limit } }
+    )
+    return response.data.logs || []
+  }
+
+  async getWorkerMetrics(projectId: number, workerId: number): Promise<Record<string, any>> {
+    const response = await this.client.get<{ success: boolean; metrics: Record<string, any> }>(
+      `/hosting/${projectId}/workers/${workerId}/metrics`
+    )
+    return response.data.metrics
+  }
+
+  // ========== PRODUCTION ERRORS ==========
+
+  /**
+   * List a project's reported production errors and the DSN its latest deployment reports to
+   */
+  async getErrorGroups(
+    projectId: number,
+    params: { deployment_id?: string; status?: HostingErrorStatus; limit?: number } = {}
+  ): Promise<{ groups: HostingErrorGroup[]; dsn?: string; deployment_id?: string }> {
+    const response = await this.client.get<{
+      success: boolean
+      groups: HostingErrorGroup[]
+      dsn?: string
+      deployment_id?: string
+    }>(`/hosting/${projectId}/errors`, { params })
+    return { groups: response.data.groups || [], dsn: response.data.dsn, deployment_id: response.data.deployment_id }
+  }
+
+  async getErrorGroup(
+    projectId: number,
+    groupId: number
+  ): Promise<{ group: HostingErrorGroup; events: HostingErrorEvent[] }> {
+    const response = await this.client.get<{
+      success: boolean
+      group: HostingErrorGroup
+      events: HostingErrorEvent[]
+    }>(`/hosting/${projectId}/errors/${groupId}`)
+    return { group: response.data.group, events: response.data.events || [] }
+  }
+
+  async updateErrorGroup(projectId: number, groupId: number, status: HostingErrorStatus): Promise<HostingErrorGroup> {
+    const response = await this.client.patch<{ success: boolean; group: HostingErrorGroup }>(
+      `/hosting/${projectId}/errors/${groupId}`,
+      { status }
+    )
+    return response.data.group
+  }
+
+  /**
+   * Ask the Solver agent to diagnose an error from its latest report and the files in its stack
+   */
+  async solveErrorGroup(
+    projectId: number,
+    groupId: number
+  ): Promise<{ analysis: string; files: string[]; provider: string }> {
+    const response = await this.client.post<{
+      success: boolean
+      analysis: string
+      files: string[]
+      provider: string
+    }>(`/hosting/${projectId}/errors/${groupId}/solve`)
+    return { analysis: response.data.analysis, files: response.data.files || [], provider: response.data.provider }
+  }
+
+  // ========== LOG DRAINS ==========
+
+  /**
+   * List a project's log drains with their delivery status
+   */
+  async getLogDrains(projectId: number): Promise<LogDrain[]> {
+    const response = await this.client.get<{ success: boolean; drains: LogDrain[] }>(
+      `/hosting/${projectId}/log-drains`
+    )
+    return response.data.drains || []
+  }
+
+  async createLogDrain(projectId: number, data: LogDrainInput): Promise<LogDrain> {
+    const response = await this.client.post<{ success: boolean; drain: LogDrain }>(
+      `/hosting/${projectId}/log-drains`,
+      data
+    )
+    return response.data.drain
+  }
+
+  async updateLogDrain(projectId: number, drainId: number, data: Partial<Omit<LogDrainInput, 'type'>>): Promise<LogDrain> {
+    const response = await this.client.patch<{ success: boolean; drain: LogDrain }>(
+      `/hosting/${projectId}/log-drains/${drainId}`,
+      data
+    )
+    return response.data.drain
+  }
+
+  async deleteLogDrain(projectId: number, drainId: number): Promise<void> {
+    await this.client.delete(`/hosting/${projectId}/log-drains/${drainId}`)
+  }
+
+  /**
+   * Send a test line to a log drain and return its updated delivery status
+   */
+  async testLogDrain(projectId: number, drainId: number): Promise<{ delivered: boolean; drain: LogDrain; error?: string }> {
+    const response = await this.client.post<{
+      success: boolean
+      delivered: boolean
+      drain: LogDrain
+      error?: string
+    }>(`/hosting/${projectId}/log-drains/${drainId}/test`)
+    return { delivered: response.data.delivered, drain: response.data.drain, error: response.data.error }
+  }
+
+  // ========== SECURITY HEADERS ==========
+
+  /**
+   * Get a deployment's security headers: configured, effective and the framework defaults
+   */
+  async getSecurityHeaders(projectId: number, deploymentId: string): Promise<DeploymentSecurityHeaders> {
+    const response = await this.client.get<DeploymentSecurityHeaders & { success: boolean }>(
+      `/projects/${projectId}/deployments/${deploymentId}/security-headers`
+    )
+    return response.data
+  }
+
+  /**
+   * Set a deployment's security headers; an empty object restores the defaults
+   */
+  async updateSecurityHeaders(projectId: number, deploymentId: string, headers: SecurityHeaders): Promise<DeploymentSecurityHeaders> {
+    const response = await this.client.put<DeploymentSecurityHeaders & { success: boolean }>(
+      `/projects/${projectId}/deployments/${deploymentId}/security-headers`,
+      headers
+    )
+    return response.data
+  }
+
+  /**
+   * List CSP violations reported by visitors' browsers
+   */
+  async getCSPViolations(projectId: number, deploymentId: string, limit?: number): Promise<CSPViolation[]> {
+    const response = await this.client.get<{ success: boolean; violations: CSPViolation[] }>(
+      `/projects/${projectId}/deployments/${deploymentId}/csp-reports`,
+      { params: { limit } }
+    )
+    return response.data.violations || []
+  }
+
+  /**
+   * Get WebSocket URL for deployment logs streaming
+   */
+  getDeploymentLogsWebSocketUrl(deploymentId: string): string {
+    return buildAuthenticatedWebSocketUrl(
+      `${resolveWebSocketBaseUrl(this.baseURL, window.location.host)}/deploy/${encodeURIComponent(deploymentId)}`
+    )
+  }
+
+  // ========== PLAN USAGE QUOTA ENDPOINTS ==========
+
+  /**
+   * Get current usage and limits for the authenticated user
+   * Returns usage across all tracked dimensions: projects, storage, AI requests, execution time
+   */
+  async getCurrentUsage(): Promise<CurrentUsageData> {
+    const response = await this.client.get<{ success: boolean; data: CurrentUsageData }>(
+      '/usage/current'
+    )
+    return response.data.data
+  }
+
+  /**
+   * Get historical usage data for charts and trend analysis
+   */
+  async getUsageHistory(days: number = 30, months: number = 12): Promise<UsageHistoryData> {
+    const response = await this.client.get<{ success: boolean; data: UsageHistoryData }>(
+      `/usage/history?days=${days}&months=${months}`
+    )
+    return response.data.data
+  }
+
+  /**
+   * Get closed billing-period snapshots for an organization
+   */
+  async getOrgUsageHistory(orgId: number, months: number = 12): Promise<{ organization_id: number; months: number; periods: UsagePeriod[] }> {
+    const response = await this.client.get<{ success: boolean; data: { organization_id: number; months: number; periods: UsagePeriod[] } }>(
+      `/usage/history?organization_id=${orgId}&months=${months}`
+    )
+    return response.data.data
+  }
+
+  /**
+   * Get per-project storage consumption counted against the storage quota
+   */
+  async getStorageBreakdown(): Promise<StorageBreakdown> {
+    const response = await this.client.get<{ success: boolean; data: StorageBreakdown }>(
+      '/usage/storage'
+    )
+    return response.data.data
+  }
+
+  /**
+   * Get plan limits for the current user and all available plans
+   * Useful for showing upgrade comparison
+   */
+  async getUsageLimits(): Promise<UsageLimitsData> {
+    const response = await this.client.get<{ success: boolean; data: UsageLimitsData }>(
+      '/usage/limits'
+    )
+    return response.data.data
+  }
+
+  /**
+   * Force refresh the usage cache (useful after major operations)
+   */
+  async refreshUsageCache(): Promise<{ message: string }> {
+    const response = await this.client.post<{ success: boolean; message: string }>(
+      '/usage/refresh'
+    )
+    return { message: response.data.message }
+  }
+
+  // ========== PROJECT ASSET ENDPOINTS ==========
+
+  async listAssets(projectId: number): Promise<import('@/types').ProjectAsset[]> {
+    const response = await this.client.get<{
+      success: boolean
+      assets: import('@/types').ProjectAsset[]
+      count: number
+    }>(`/projects/${projectId}/assets`)
+    return response.data.assets || []
+  }
+
+  async uploadAsset(projectId: number, nativeFile: Blob): Promise<{
+    asset: import('@/types').ProjectAsset
+    message: string
+  }> {
+    const formData = new FormData()
+    formData.append('file', nativeFile)
+    const response = await this.client.post<{
+      success: boolean
+      asset: import('@/types').ProjectAsset
+      message: string
+    }>(`/projects/${projectId}/assets`, formData, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    })
+    return { asset: response.data.asset, message: response.data.message }
+  }
+
+  async deleteAsset(projectId: number, assetId: number): Promise<void> {
+    await this.client.delete(`/projects/${projectId}/assets/${assetId}`)
+  }
+
+  // ── Billing: credit purchase & balance ───────────────────────────────────
+
+  async purchaseCredits(params: {
+    amount_usd: number
+    success_url: string
+    cancel_url: string
+  }): Promise<{ success: boolean; data?: { session_id: string; checkout_url: string }; error?: string }> {
+    const response = await this.client.post('/billing/credits/purchase', params)
+    return response.data
+  }
+
+  async getCreditBalance(): Promise<{
+    success: boolean
+    data?: {
+      balance: number
+      has_unlimited: boolean
+      bypass_billing: boolean
+      available_packs: Array<{ amount_usd: number; credit_usd: number; label: string }>
+    }
+  }> {
+    const response = await this.client.get('/billing/credits/balance')
+    return response.data
+  }
+
+  async getBuildSpend(buildId: string): Promise<{
+    success: boolean
+    data?: {
+      total_spend: number
+      events: Array<Record<string, unknown>>
+    }
+  }> {
+    const response = await this.client.get(`/spend/build/${encodeURIComponent(buildId)}`)
+    return response.data
+  }
+
+  async getSubscription(): Promise<{
+    success: boolean
+    data?: {
+      plan_type: string
+      plan_name: string
+      status: string
+      current_period_end: string
+      cancel_at_period_end?: boolean
+      cancel_at?: string
+      monthly_credits_usd?: number
+    }
+  }> {
+    const response = await this.client.get('/billing/subscription')
+    return response.data
+  }
+
+  async getPlans(): Promise<{
+    success: boolean
+    data?: {
+      plans: Array<{
+        type: string
+        name: string
+        monthly_price_cents: number
+        monthly_price_id: string
+        monthly_credits_usd: number
+        is_popular: boolean
+        features: string[]
+      }>
+      self_serve_ready?: boolean
+      stripe_configured?: boolean
+      webhook_configured?: boolean
+      required_price_ids_configured?: boolean
+    }
+  }> {
+    const response = await this.client.get('/billing/plans')
+    return response.data
+  }
+
+  async createCheckoutSession(params: {
+    price_id: string
+    success_url?: string
+    cancel_url?: string
+    apply_promo?: boolean
+  }): Promise<{ success: boolean; data?: { session_id: string; checkout_url: string }; error?: string }> {
+    const response = await this.client.post('/billing/checkout', params)
+    return response.data
+  }
+
+  async createBillingPortalSession(return_url: string): Promise<{
+    success: boolean
+    data?: { portal_url: string }
+    error?: string
+  }> {
+    const response = await this.client.post('/billing/portal', { return_url })
+    return response.data
+  }
+
+  async changePlan(params: {
+    plan_type: string
+    billing_cycle: 'monthly' | 'annual'
+  }): Promise<{
+    success: boolean
+    data?: {
+      plan_type: string
+      billing_cycle: string
+      status: string
+      current_period_end: string
+    }
+    error?: string
+  }> {
+    const response = await this.client.post('/billing/change-plan', params)
+    return response.data
+  }
+
+  async getInvoices(): Promise<{
+    success: boolean
+    data?: {
+      invoices: Array<{
+        id: string
+        amount_paid: number
+        currency: string
+        status: string
+        created: number
+        hosted_invoice_url: string
+        description: string
+      }>
+    }
+  }> {
+    const response = await this.client.get('/billing/invoices')
+    return response.data
+  }
+
+  // ---------------------------------------------------------------------------
+  // Enterprise API
+  // ---------------------------------------------------------------------------
+
+  async getOrganizations(): Promise<{
+    success: boolean
+    organizations?: Organization[]
+    error?: string
+  }> {
+    const response = await this.client.get('/enterprise/organizations')
+    return response.data
+  }
+
+  async getOrganization(id: number): Promise<{
+    success: boolean
+    organization?: Organization
+    error?: string
+  }> {
+    const response = await this.client.get(`/enterprise/organizations/${id}`)
+    return response.data
+  }
+
+  async configureSSO(id: number, config: SSOConfigRequest): Promise<{
+    success: boolean
+    message?: string
+    error?: string
+  }> {
+    const response = await this.client.post(`/enterprise/organizations/${id}/sso`, config)
+    return response.data
+  }
+
+  async getAuditLogs(id: number, params?: {
+    action?: string
+    category?: string
+    page?: number
+    page_size?: number
+  } & ListParams): Promise<{
+    success: boolean
+    audit_logs?: AuditLog[]
+    total?: number
+    page?: number
+    page_size?: number
+    page_info?: PageInfo
+    error?: string
+  }> {
+    const response = await this.client.get(`/enterprise/organizations/${id}/audit-logs`, { params })
+    return response.data
+  }
+
+  async getRoles(id: number): Promise<{
+    success: boolean
+    roles?: Role[]
+    error?: string
+  }> {
+    const response = await this.client.get(`/enterprise/organizations/${id}/roles`)
+    return response.data
+  }
+
+  async updateOrganizationAIInstructions(id: number, data: {
+    instructions?: string
+    allow_project_instructions?: boolean
+  }): Promise<{ success: boolean; organization?: Organization; error?: string }> {
+    const response = await this.client.put(`/enterprise/organizations/${id}/ai-instructions`, data)
+    return response.data
+  }
+
+  async getOrganizationCodingStandards(id: number): Promise<OrganizationCodingStandardsResponse> {
+    const response = await this.client.get(`/enterprise/organizations/${id}/coding-standards`)
+    return response.data
+  }
+
+  async updateOrganizationCodingStandards(id: number, policy: CodingStandardsPolicy): Promise<OrganizationCodingStandardsResponse> {
+    const response = await this.client.put(`/enterprise/organizations/${id}/coding-standards`, policy)
+    return response.data
+  }
+
+  async getOrganizationEncryption(id: number): Promise<{
+    success: boolean
+    enabled: boolean
+    active?: OrganizationDataKey | null
+    keys: OrganizationDataKey[]
+  }> {
+    const response = await this.client.get(`/enterprise/organizations/${id}/encryption`)
+    return response.data
+  }
+
+  /**
+   * Enable organization encryption keys, or move them to another KMS key
+   */
+  async configureOrganizationEncryption(id: number, data: {
+    provider: OrganizationKMSProvider
+    kms_key_id?: string
+  }): Promise<OrganizationKeyRotationResponse> {
+    const response = await this.client.put(`/enterprise/organizations/${id}/encryption`, data)
+    return response.data
+  }
+
+  async rotateOrganizationEncryption(id: number): Promise<OrganizationKeyRotationResponse> {
+    const response = await this.client.post(`/enterprise/organizations/${id}/encryption/rotate`)
+    return response.data
+  }
+
+  /**
+   * Delete an organization and destroy its encryption keys. confirm must be the organization slug.
+   */
+  async deleteOrganization(id: number, confirm: string): Promise<{ success: boolean; destroyed_keys: number }> {
+    const response = await this.client.delete(`/enterprise/organizations/${id}`, { params: { confirm } })
+    return response.data
+  }
+
+  /**
+   * Get an organization's IP allowlist and geo restriction policy
+   */
+  async getOrganizationNetworkPolicy(id: number): Promise<OrganizationNetworkPolicy> {
+    const response = await this.client.get<{ success: boolean; policy: OrganizationNetworkPolicy }>(
+      `/enterprise/organizations/${id}/network-policy`
+    )
+    return response.data.policy
+  }
+
+  /**
+   * Replace an organization's network policy. Fails with 409 when the policy would block the caller.
+   */
+  async updateOrganizationNetworkPolicy(id: number, data: OrganizationNetworkPolicyInput): Promise<OrganizationNetworkPolicy> {
+    const response = await this.client.put<{ success: boolean; policy: OrganizationNetworkPolicy }>(
+      `/enterprise/organizations/${id}/network-policy`,
+      data
+    )
+    return response.data.policy
+  }
+
+  /**
+   * Get an organization's AI data residency policy and the regions it can allow
+   */
+  async getOrganizationAIRegionPolicy(id: number): Promise<{ policy: OrganizationAIRegionPolicy; available_regions: AIRegion[] }> {
+    const response = await this.client.get<{ success: boolean; policy: OrganizationAIRegionPolicy; available_regions: AIRegion[] }>(
+      `/enterprise/organizations/${id}/ai-region-policy`
+    )
+    return { policy: response.data.policy, available_regions: response.data.available_regions }
+  }
+
+  /**
+   * Replace an organization's AI region policy
+   */
+  async updateOrganizationAIRegionPolicy(id: number, data: OrganizationAIRegionPolicyInput): Promise<OrganizationAIRegionPolicy> {
+    const response = await this.client.put<{ success: boolean; policy: OrganizationAIRegionPolicy }>(
+      `/enterprise/organizations/${id}/ai-region-policy`,
+      data
+    )
+    return response.data.policy
+  }
+
+  /**
+   * Sign organization members out of every device; all members except the caller when userId is omitted.
+   */
+  async forceLogoutOrganizationMembers(id: number, userId?: number): Promise<{ success: boolean; members: number; revoked_sessions: number }> {
+    const path = userId
+      ? `/enterprise/organizations/${id}/members/${userId}/logout`
+      : `/enterprise/organizations/${id}/logout`
+    const response = await this.client.post(path)
+    return response.data
+  }
+
+  /**
+   * List the SSO identities linked to the current account.
+   */
+  async listUserIdentities(): Promise<{ success: boolean; identities: UserIdentity[]; has_password: boolean }> {
+    const response = await this.client.get('/user/identities')
+    return response.data
+  }
+
+  /**
+   * Link the SSO identity from a `link_required` SSO sign-in to the current account.
+   */
+  async linkPendingIdentity(linkToken: string): Promise<{ success: boolean; identity: UserIdentity }> {
+    const response = await this.client.post('/user/identities/link', { link_token: linkToken })
+    return response.data
+  }
+
+  /**
+   * Unlink an SSO identity from the current account.
+   */
+  async unlinkUserIdentity(identityId: number): Promise<{ success: boolean }> {
+    const response = await this.client.delete(`/user/identities/${identityId}`)
+    return response.data
+  }
+
+  /**
+   * Merge duplicate member accounts by email. Only reports the plan unless dryRun is false.
+   */
+  async runOrganizationIdentityMigration(id: number, dryRun = true): Promise<IdentityMigrationReport> {
+    const response = await this.client.post<{ success: boolean; report: IdentityMigrationReport }>(
+      `/enterprise/organizations/${id}/identity-migration`,
+      { dry_run: dryRun }
+    )
+    return response.data.report
+  }
+
+  /**
+   * List the email domains an organization has claimed for SSO account linking.
+   */
+  async listOrganizationDomains(id: number): Promise<OrganizationDomain[]> {
+    const response = await this.client.get<{ success: boolean; domains: OrganizationDomain[] }>(
+      `/enterprise/organizations/${id}/domains`
+    )
+    return response.data.domains
+  }
+
+  /**
+   * Claim an email domain. Publish the returned TXT record, then verify it.
+   */
+  async addOrganizationDomain(
+    id: number,
+    domain: string
+  ): Promise<{ success: boolean; domain: OrganizationDomain; dns_record: { type: 'TXT'; name: string; value: string } }> {
+    const response = await this.client.post(`/enterprise/organizations/${id}/domains`, { domain })
+    return response.data
+  }
+
+  /**
+   * Check a claimed domain's TXT record.
+   */
+  async verifyOrganizationDomain(id: number, domainId: number): Promise<OrganizationDomain> {
+    const response = await this.client.post<{ success: boolean; domain: OrganizationDomain }>(
+      `/enterprise/organizations/${id}/domains/${domainId}/verify`
+    )
+    return response.data.domain
+  }
+
+  /**
+   * Release a claimed domain.
+   */
+  async deleteOrganizationDomain(id: number, domainId: number): Promise<{ success: boolean }> {
+    const response = await this.client.delete(`/enterprise/organizations/${id}/domains/${domainId}`)
+    return response.data
+  }
+
+  /**
+   * Merge one member account into another with the same email.
+   */
+  async mergeOrganizationMemberAccounts(
+    id: number,
+    data: { source_user_id: number; target_user_id: number; dry_run?: boolean }
+  ): Promise<AccountMergePlan> {
+    const response = await this.client.post<{ success: boolean; dry_run: boolean; plan: AccountMergePlan }>(
+      `/enterprise/organizations/${id}/members/merge`,
+      data
+    )
+    return response.data.plan
+  }
+}
+
+export interface PlatformDailyMetric {
+  day: string
+  signups: number
+  activated_users: number
+  activation_rate: number
+  active_users: number
+  paid_users: number
+  builds: number
+  builds_failed: number
+  ai_requests: number
+  ai_cost_usd: number
+  revenue_usd: number
+  gross_margin_usd: number
+  updated_at: string
+}
+
+export interface AdminAnalyticsTrends {
+  from: string
+  to: string
+  days: PlatformDailyMetric[]
+  totals: Omit<PlatformDailyMetric, 'day' | 'active_users' | 'paid_users' | 'updated_at'>
+}
+
+export interface PlanDailyMetric {
+  day: string
+  plan: string
+  builds: number
+  builders: number
+  active_users: number
+  ai_requests: number
+  ai_cost_usd: number
+  revenue_usd: number
+  updated_at: string
+}
+
+export interface RetentionCohort {
+  cohort_week: string
+  week_offset: number
+  cohort_size: number
+  retained_users: number
+  retention_rate: number
+  updated_at: string
+}
+
+export interface WarehouseExport {
+  id: number
+  created_at: string
+  sink: 's3' | 'bigquery'
+  table: string
+  partition?: string
+  location: string
+  rows: number
+  bytes: number
+  status: 'succeeded' | 'failed'
+  error?: string
+  duration_ms: number
+}
+
+export interface UserIdentity {
+  id: number
+  user_id: number
+  provider: 'saml' | 'oidc'
+  issuer: string
+  subject: string
+  organization_id?: number
+  email: string
+  linked_by: 'login' | 'email_match' | 'merge' | 'session'
+  last_used_at?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface OrganizationDomain {
+  id: number
+  organization_id: number
+  domain: string
+  verification_token: string
+  verified_at?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface AccountMergePlan {
+  source_user_id: number
+  source_email: string
+  target_user_id: number
+  target_email: string
+  projects: number
+  identities: number
+  memberships: number
+  credit_balance_usd: number
+  move_subscription: boolean
+  blockers?: string[]
+}
+
+export interface IdentityMigrationReport {
+  organization_id: number
+  dry_run: boolean
+  members: number
+  merges: number
+  link_on_login: number
+  skipped: number
+  failed: number
+  items: Array<{
+    email: string
+    action: 'merge' | 'link_on_login' | 'skip'
+    target_user_id?: number
+    merges?: AccountMergePlan[]
+    reason?: string
+    applied: boolean
+    error?: string
+  }>
+}
+
+export interface OrganizationNetworkPolicyInput {
+  enabled: boolean
+  enforce_api?: boolean
+  enforce_sso?: boolean
+  allowed_cidrs?: string[]
+  allowed_countries?: string[]
+  blocked_countries?: string[]
+  break_glass_user_ids?: number[]
+}
+
+export interface OrganizationNetworkPolicy extends Required<OrganizationNetworkPolicyInput> {
+  id: number
+  organization_id: number
+  updated_by?: number
+  created_at: string
+  updated_at: string
+}
+
+export type AIRegion = 'us' | 'eu'
+
+export interface OrganizationAIRegionPolicyInput {
+  enabled: boolean
+  allowed_regions?: AIRegion[]
+}
+
+export interface OrganizationAIRegionPolicy extends Required<OrganizationAIRegionPolicyInput> {
+  id: number
+  organization_id: number
+  updated_by?: number
+  created_at: string
+  updated_at: string
+}
+
+export interface UserSession {
+  id: string
+  device: string
+  device_id?: string
+  ip_address: string
+  user_agent: string
+  created_at: string
+  last_active_at: string
+  expires_at: string
+  current: boolean
+}
+
+export type OrganizationKMSProvider = 'local' | 'aws-kms' | 'gcp-kms'
+
+export interface OrganizationDataKey {
+  id: number
+  organization_id: number
+  version: number
+  provider: OrganizationKMSProvider
+  kms_key_id?: string
+  fingerprint: string
+  status: 'active' | 'retired' | 'destroyed'
+  retired_at?: string
+  destroyed_at?: string
+  created_by: number
+  created_at: string
+  updated_at: string
+}
+
+export interface OrganizationKeyRotationResponse {
+  success: boolean
+  key: OrganizationDataKey
+  rotation: { reencrypted: number; failed: number; errors?: string[] }
+}
+
+export interface TerminalSessionResponse {
+  session_id: string
+  project_id: number
+  work_dir: string
+  shell: string
+  name: string
+  rows: number
+  cols: number
+  created_at: string
+  ws_endpoint: string
+}
+
+export interface TerminalSessionCreateResponse {
+  success: boolean
+  data: TerminalSessionResponse
+}
+
+export interface TerminalSessionInfo {
+  id: string
+  project_id: number
+  user_id: number
+  work_dir: string
+  shell: string
+  shell_name?: string
+  name: string
+  created_at: string
+  last_active: string
+  is_active: boolean
+  rows: number
+  cols: number
+  ws_endpoint: string
+}
+
+export interface AvailableShell {
+  name: string
+  path: string
+}
+
+// ---------------------------------------------------------------------------
+// Always-On Deployment types (Replit parity feature)
+// ---------------------------------------------------------------------------
+
+export type ExternalDeploymentProviderId =
+  | 'vercel'
+  | 'netlify'
+  | 'render'
+  | 'railway'
+  | 'cloudflare_pages'
+
+export interface ExternalDeploymentProvider {
+  id: ExternalDeploymentProviderId
+  name: string
+  description: string
   features: string[]
 }
 
-export interface ExternalDeploymentDatabaseConfig {
-  provider: 'neon'
-  project_name?: string
-  branch_name?: string
-  database_name?: string
-  role_name?: string
-  region_id?: string
-  org_id?: string
-  pg_version?: number
-  pooled?: boolean
+export interface ExternalDeploymentDatabaseConfig {
+  provider: 'neon'
+  project_name?: string
+  branch_name?: string
+  database_name?: string
+  role_name?: string
+  region_id?: string
+  org_id?: string
+  pg_version?: number
+  pooled?: boolean
+}
+
+export interface ExternalDeploymentConfig {
+  project_id: number
+  provider: ExternalDeploymentProviderId
+  environment?: string
+  branch?: string
+  env_vars?: Record<string, string>
+  build_command?: string
+  output_dir?: string
+  install_cmd?: string
+  start_command?: string
+  framework?: string
+  node_version?: string
+  root_directory?: string
+  database?: ExternalDeploymentDatabaseConfig
+}
+
+export interface ExternalDeployment {
+  id: string
+  project_id: number
+  user_id: number
+  provider: ExternalDeploymentProviderId
+  status: 'pending' | 'preparing' | 'building' | 'deploying' | 'live' | 'failed' | 'cancelled'
+  url?: string
+  preview_url?: string
+  environment: string
+  branch: string
+  commit_sha?: string
+  commit_msg?: string
+  build_time?: number
+  deploy_time?: number
+  total_time?: number
+  error_message?: string
+  config?: Record<string, unknown>
+  metadata?: Record<string, unknown>
+  started_at?: string
+  completed_at?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface DeploymentCostEstimate {
+  project_id: number
+  profile: {
+    project_type: string
+    workload: 'static' | 'server_rendered' | 'server'
+    file_count: number
+    bundle_bytes: number
+    always_on: boolean
+    always_on_reasons?: string[]
+  }
+  monthly_page_views: number
+  // Cheapest viable first
+  providers: {
+    provider: ExternalDeploymentProviderId | 'native'
+    name: string
+    available: boolean
+    viable: boolean
+    reason?: string
+    plan?: string
+    monthly_usd: number
+    bandwidth_gb: number
+    notes?: string[]
+  }[]
+  recommended?: ExternalDeploymentProviderId | 'native'
+  prices_as_of: string
+}
+
+export interface ExternalDeploymentLog {
+  id: number
+  deployment_id: string
+  timestamp: string
+  level: 'debug' | 'info' | 'warn' | 'error'
+  message: string
+  phase?: string
+  metadata?: string
+}
+
+export interface AlwaysOnStatus {
+  always_on: boolean
+  always_on_enabled: string | null
+  last_keep_alive: string | null
+  keep_alive_interval: number
+  sleep_after_minutes: number
+  restart_count: number
+  max_restarts: number
+  next_restart_at: string | null
+  crash_loop_detected_at: string | null
+  container_status: 'healthy' | 'unhealthy' | 'starting' | 'stopped'
+  uptime_seconds: number
+}
+
+export interface DeploymentHealthCheck {
+  path: string
+  timeout_seconds: number
+  interval_seconds: number
+}
+
+export interface DeploymentEvent {
+  id: number
+  created_at: string
+  deployment_id: string
+  event_type: 'restart' | 'crash_loop' | 'scale' | string
+  event_status: 'success' | 'error' | 'warning'
+  message: string
+  // JSON-encoded RestartEventDetails for restart and crash_loop events,
+  // ScaleEventDetails for scale events
+  metadata?: string
+  duration?: number
+}
+
+export interface RestartEventDetails {
+  reason: 'manual' | 'config_change' | 'crashed' | 'stopped' | 'unhealthy'
+  automatic: boolean
+  attempt?: number
+  old_container?: string
+  new_container?: string
+  health_url?: string
+  health_status?: number
+  error?: string
+}
+
+export interface DeploymentEventsResponse {
+  events: DeploymentEvent[]
+  restarts: {
+    restart_count: number
+    max_restarts: number
+    next_restart_at: string | null
+    crash_loop_detected_at: string | null
+  }
+  health_check: DeploymentHealthCheck
+}
+
+export interface AutoscaleConfig {
+  enabled: boolean
+  min_instances: number
+  max_instances: number
+  target_rps_per_instance: number
+  target_latency_ms: number
+}
+
+export interface DeploymentLoadStats {
+  window_seconds: number
+  requests: number
+  requests_per_second: number
+  avg_latency_ms: number
+  max_latency_ms: number
+  error_rate: number
+}
+
+export interface DeploymentLoadPoint {
+  minute: string
+  requests: number
+  errors: number
+  avg_latency_ms: number
+  max_latency_ms: number
+}
+
+// Metadata of scale events, also sent as the deployment WebSocket's "scale" message
+export interface ScaleEventDetails {
+  from: number
+  to: number
+  reason: 'request_rate' | 'latency' | 'low_load' | 'min_instances' | 'max_instances' | 'autoscale_disabled'
+  requests_per_second: number
+  avg_latency_ms: number
+  error?: string
+}
+
+export interface DeploymentAutoscaleStatus {
+  autoscale: AutoscaleConfig
+  current_instances: number
+  last_scaled_at: string | null
+  plan_max: number
+  load: DeploymentLoadStats
+  history: DeploymentLoadPoint[]
+  events: DeploymentEvent[]
+}
+
+export interface NativeDeploymentConfig {
+  subdomain?: string
+  port?: number
+  build_command?: string
+  start_command?: string
+  install_command?: string
+  framework?: string
+  node_version?: string
+  python_version?: string
+  go_version?: string
+  memory_limit?: number
+  cpu_limit?: number
+  health_check_path?: string
+  auto_scale?: boolean
+  min_instances?: number
+  max_instances?: number
+  env_vars?: Record<string, string>
+  always_on?: boolean
+  keep_alive_interval?: number
+}
+
+export interface NativeDeployment {
+  id: string
+  project_id: number
+  user_id: number
+  subdomain: string
+  url: string
+  preview_url?: string
+  status: 'pending' | 'provisioning' | 'building' | 'deploying' | 'running' | 'stopped' | 'failed' | 'deleted'
+  container_status: 'healthy' | 'unhealthy' | 'starting' | 'stopped'
+  error_message?: string
+  container_port: number
+  build_command?: string
+  start_command?: string
+  framework?: string
+  memory_limit: number
+  cpu_limit: number
+  auto_scale: boolean
+  min_instances: number
+  max_instances: number
+  current_instances: number
+  always_on: boolean
+  always_on_enabled?: string
+  last_keep_alive?: string
+  keep_alive_interval: number
+  total_requests: number
+  avg_response_time: number
+  uptime_seconds: number
+  created_at: string
+  updated_at: string
+  deployed_at?: string
+  build_duration?: number
+  deploy_duration?: number
+}
+
+export interface DeploymentLog {
+  id: number
+  deployment_id: string
+  timestamp: string
+  level: 'debug' | 'info' | 'warn' | 'error'
+  source: string
+  message: string
+  metadata?: string
+}
+
+export interface DeploymentMetrics {
+  total_requests: number
+  avg_response_time: number
+  uptime_seconds: number
+  bandwidth_used: number
+  current_instances: number
+  container_status: string
+  last_request_at?: string
+  last_health_check?: string
+  memory_limit: number
+  cpu_limit: number
+  load: DeploymentLoadStats
+  autoscale: AutoscaleConfig
+  scale_events?: DeploymentEvent[]
+}
+
+// ---------------------------------------------------------------------------
+// AI Code Review types (matching backend codereview.ReviewResponse)
+// ---------------------------------------------------------------------------
+
+export interface CodeReviewFinding {
+  type: string       // bug, security, performance, style, best_practice
+  severity: string   // error, warning, info, hint
+  line: number
+  end_line: number
+  column: number
+  end_column: number
+  message: string
+  suggestion: string
+  code: string
+  rule_id: string
+}
+
+export interface CodeReviewMetrics {
+  total_lines: number
+  code_lines: number
+  comment_lines: number
+  blank_lines: number
+  complexity: number
+  security_issues: number
+  bug_risks: number
+  style_issues: number
+}
+
+export interface CodeReviewResponse {
+  findings: CodeReviewFinding[]
+  summary: string
+  score: number
+  metrics: CodeReviewMetrics
+  suggestions: string[]
+  reviewed_at: string
+  duration_ms: number
+}
+
+// ---------------------------------------------------------------------------
+// Package Management types (matching backend packages.go)
+// ---------------------------------------------------------------------------
+
+export type PackageRegistry = 'npm' | 'pip' | 'go'
+
+export interface PackageSearchResult {
+  name: string
+  version: string
+  description: string
+  homepage?: string
+  repository?: string
+  license?: string
+  downloads?: number
+  keywords?: string[]
+}
+
+export interface PackageInfo {
+  name: string
+  version: string
+  description: string
+  homepage?: string
+  repository?: string
+  license?: string
+  author?: string
+  dependencies?: Record<string, string>
+  dev_dependencies?: Record<string, string>
+  versions?: string[]
+  readme?: string
+}
+
+export interface InstalledPackage {
+  name: string
+  version: string
+  is_dev: boolean
+  type: PackageRegistry
+}
+
+export interface PackageSuggestion {
+  name: string
+  description: string
+  category: string
+  is_dev: boolean
+}
+
+// ---------------------------------------------------------------------------
+// Debug System types (matching backend debugging handlers)
+// ---------------------------------------------------------------------------
+
+export type DebugSessionStatus = 'pending' | 'running' | 'paused' | 'completed' | 'error'
+export type DebugBreakpointType = 'line' | 'conditional' | 'logpoint' | 'exception' | 'function'
+
+export interface DebugSession {
+  id: string
+  project_id: number
+  user_id: number
+  file_id: number
+  status: DebugSessionStatus
+  language: string
+  entry_point: string
+  working_directory: string
+  debug_port: number
+  devtools_url?: string
+  process_id?: number
+  error_message?: string
+  started_at?: string
+  ended_at?: string
+  current_line?: number
+  current_file?: string
+}
+
+export interface DebugBreakpoint {
+  id: string
+  session_id: string
+  file_id: number
+  file_path: string
+  line: number
+  column: number
+  type: DebugBreakpointType
+  condition?: string
+  log_message?: string
+  hit_count: number
+  enabled: boolean
+  verified: boolean
+  breakpoint_id?: string
+}
+
+export interface DebugStackFrame {
+  id: string
+  index: number
+  function_name: string
+  file_path: string
+  line: number
+  column: number
+  script_id?: string
+  is_async: boolean
+  scopes?: DebugScope[]
+  local_vars?: Record<string, string>
+}
+
+export interface DebugScope {
+  type: 'local' | 'closure' | 'global' | 'with' | 'catch' | 'block' | 'script'
+  name?: string
+  start_line?: number
+  end_line?: number
+  variables?: DebugVariable[]
+}
+
+export interface DebugVariable {
+  name: string
+  value: string
+  type: string
+  object_id?: string
+  has_children: boolean
+  children?: DebugVariable[]
+  preview?: string
+}
+
+export interface DebugWatchExpression {
+  id: string
+  expression: string
+  value?: string
+  type?: string
+  error?: string
+}
+
+export interface DebugEvaluateResult {
+  value: string
+  type: string
+  object_id?: string
+  has_children: boolean
+  preview?: string
+  error?: string
+}
+
+export interface DebugEvent {
+  type: DebugEventType
+  timestamp: string
+  data: any
+}
+
+export type DebugEventType =
+  | 'session_started'
+  | 'session_stopped'
+  | 'paused'
+  | 'resumed'
+  | 'stepping'
+  | 'breakpoint_hit'
+  | 'breakpoint_verified'
+  | 'breakpoint_added'
+  | 'breakpoint_removed'
+  | 'exception'
+  | 'output'
+  | 'error'
+
+// ---------------------------------------------------------------------------
+// Notebook types (nbformat v4)
+// ---------------------------------------------------------------------------
+
+export type NotebookKernelStatus = 'starting' | 'idle' | 'busy' | 'dead'
+
+export interface NotebookKernel {
+  id: string
+  project_id: number
+  language: string
+  status: NotebookKernelStatus
+  execution_count: number
+  started_at: string
+  last_activity: string
+}
+
+export interface NotebookOutput {
+  output_type: 'stream' | 'execute_result' | 'display_data' | 'error'
+  name?: 'stdout' | 'stderr'
+  text?: string[]
+  // MIME bundle, e.g. image/png (base64), application/json, text/html, text/plain
+  data?: Record<string, any>
+  metadata?: Record<string, any>
+  execution_count?: number
+  ename?: string
+  evalue?: string
+  traceback?: string[]
+}
+
+export interface NotebookCell {
+  id?: string
+  cell_type: 'code' | 'markdown' | 'raw'
+  source: string | string[]
+  metadata: Record<string, any>
+  execution_count?: number | null
+  outputs?: NotebookOutput[]
+}
+
+export interface NotebookDocument {
+  cells: NotebookCell[]
+  metadata: Record<string, any>
+  nbformat: number
+  nbformat_minor: number
+}
+
+export interface NotebookExecutionResult {
+  execution_id: string
+  cell_id?: string
+  status: 'ok' | 'error' | 'aborted'
+  execution_count: number
+  outputs: NotebookOutput[]
+  duration_ms: number
+}
+
+export interface NotebookEvent {
+  kernel_id: string
+  type: 'status' | 'output' | 'execute_complete'
+  execution_id?: string
+  cell_id?: string
+  status?: string
+  output?: NotebookOutput
+  execution_count?: number
+  timestamp: string
+}
+
+// ---------------------------------------------------------------------------
+// Scheduled task types
+// ---------------------------------------------------------------------------
+
+export interface ScheduledTaskInput {
+  name: string
+  command: string
+  // Five-field cron expression or @hourly/@daily/@weekly/@monthly/@yearly
+  cron: string
+  timezone?: string
+  timeout_seconds?: number
+  enabled?: boolean
+}
+
+export type ProjectActivityKind = 'file_edit' | 'build' | 'deployment' | 'comment' | 'collaborator_joined'
+
+export interface ProjectActivity {
+  id: number
+  project_id: number
+  occurred_at: string
+  actor_id: number
+  actor_name: string
+  kind: ProjectActivityKind
+  action: string
+  target?: string
+  summary: string
+  ref?: string
+  // Repeated edits of a file or rejoins within 10 minutes fold into one event
+  count: number
+  metadata?: Record<string, unknown>
+  created_at: string
+}
+
+export type RestorePointReason = 'manual' | 'build' | 'import' | 'bulk' | 'restore'
+
+export interface RestorePoint {
+  id: number
+  created_at: string
+  project_id: number
+  reason: RestorePointReason
+  label: string
+  created_by_id: number
+  file_count: number
+  total_size: number
+  tag?: string
+  // Protected points can only be restored or deleted by project admins
+  is_protected: boolean
+  tagged_by_id?: number
+  tagged_at?: string
+}
+
+export interface RestorePointChange {
+  path: string
+  status: 'added' | 'removed' | 'modified'
+  lines_added: number
+  lines_removed: number
+}
+
+export interface RestorePointDiff {
+  from: number
+  // 0 when compared against the current files
+  to: number
+  changes: RestorePointChange[]
+  summary: { added: number; removed: number; modified: number }
+}
+
+export interface RestorePointResult {
+  point: RestorePoint
+  // Taken just before restoring; restore it to undo
+  safety_point_id: number
+  directory?: string
+  updated: number
+  created: number
+  deleted: number
+}
+
+export interface ScheduledTask {
+  id: number
+  project_id: number
+  user_id: number
+  name: string
+  command: string
+  cron: string
+  timezone: string
+  timeout_seconds: number
+  enabled: boolean
+  next_run_at?: string
+  last_run_at?: string
+  last_status?: string
+  consecutive_failures: number
+  created_at: string
+  updated_at: string
+}
+
+export type ScheduledTaskRunStatus =
+  | 'running'
+  | 'completed'
+  | 'failed'
+  | 'timeout'
+  | 'killed'
+  | 'skipped'
+  | 'quota_exceeded'
+  | 'error'
+
+export interface ScheduledTaskRun {
+  id: number
+  task_id: number
+  project_id: number
+  trigger: 'schedule' | 'manual'
+  status: ScheduledTaskRunStatus
+  execution_id?: string
+  command: string
+  exit_code: number
+  output?: string
+  error_output?: string
+  duration_ms: number
+  started_at: string
+  completed_at?: string
+}
+
+export type DependencyUpdateCadence = 'daily' | 'weekly' | 'monthly'
+
+export interface DependencyUpdatePolicy {
+  id: number
+  project_id: number
+  user_id: number
+  enabled: boolean
+  cadence: DependencyUpdateCadence
+  auto_merge_patch: boolean
+  include_major: boolean
+  open_pr: boolean
+  ignore_packages: string[]
+  verify_command: string
+  next_run_at?: string
+  last_run_at?: string
+  last_status?: DependencyUpdateRunStatus
+}
+
+export type DependencyUpdatePolicyInput = Partial<Pick<DependencyUpdatePolicy,
+  'enabled' | 'cadence' | 'auto_merge_patch' | 'include_major' | 'open_pr' | 'ignore_packages' | 'verify_command'>>
+
+export type DependencyUpdateRunStatus =
+  | 'running'
+  | 'no_updates'
+  | 'verify_failed'
+  | 'quota_exceeded'
+  | 'pr_opened'
+  | 'change_set'
+  | 'applied'
+  | 'error'
+
+export interface DependencyUpdate {
+  name: string
+  ecosystem: 'npm' | 'pip'
+  manifest: string
+  from: string
+  to: string
+  kind: 'patch' | 'minor' | 'major'
+  dev?: boolean
+}
+
+export interface DependencyUpdateRun {
+  id: number
+  project_id: number
+  trigger: 'schedule' | 'manual'
+  status: DependencyUpdateRunStatus
+  updates: DependencyUpdate[]
+  verify_command?: string
+  execution_id?: string
+  verify_exit_code: number
+  verify_output?: string
+  files?: Record<string, string>
+  branch?: string
+  pull_request_url?: string
+  auto_merged: boolean
+  error?: string
+  started_at: string
+  completed_at?: string
+  applied_at?: string
+}
+
+export type BuildFailureCause =
+  | 'provider_error'
+  | 'truncation'
+  | 'validation'
+  | 'verification_failure'
+  | 'contract'
+  | 'timeout'
+  | 'budget'
+  | 'infrastructure'
+  | 'unknown'
+
+export interface BuildFailureCauseCount {
+  root_cause: BuildFailureCause
+  detail?: string
+  count: number
+  share: number
+  affected_users: number
+  last_seen: string
+  example_build_id: string
+}
+
+export interface FlakyBuildPattern {
+  description_hash: string
+  description: string
+  runs: number
+  failures: number
+  successes: number
+  failure_rate: number
+  flips: number
+  causes: BuildFailureCause[]
+  users: number
+  last_failed_at: string
+  recent_build_ids: string[]
+}
+
+export interface BuildFailureReport {
+  since: string
+  until: string
+  total_builds: number
+  failed_builds: number
+  cancelled_builds: number
+  failure_rate: number
+  top_causes: BuildFailureCauseCount[]
+  flaky: FlakyBuildPattern[]
+}
+
+export type SupportBundleKind = 'build' | 'deployment'
+
+export interface SupportBundle {
+  id: number
+  created_at: string
+  user_id: number
+  kind: SupportBundleKind
+  target_id: string
+  project_id?: number
+  target_status: string
+  summary?: string
+  token_prefix: string
+  size_bytes: number
+  expires_at: string
+  revoked_at?: string
+  download_count: number
+  last_downloaded_at?: string
+}
+
+export type TemplateVariableType = 'string' | 'secret' | 'enum'
+
+export interface TemplateVariable {
+  name: string
+  description: string
+  type?: TemplateVariableType
+  required: boolean
+  default?: string
+  options?: string[]
+  generate?: boolean
+}
+
+export interface TemplateDefinition {
+  id: string
+  name: string
+  description: string
+  category: string
+  language: string
+  framework?: string
+  icon: string
+  tags: string[]
+  difficulty: string
+  env_vars?: TemplateVariable[]
+  popular: boolean
+  new: boolean
+}
+
+export interface TemplateVariablePrompt {
+  name: string
+  description: string
+  type: TemplateVariableType
+  input: 'text' | 'password' | 'select'
+  required: boolean
+  default?: string
+  options?: string[]
+  optional: boolean
+  generated?: boolean
+}
+
+export type BuildFileOrigin = 'ai' | 'scaffold' | 'platform'
+
+export interface BuildFileProvenance {
+  path: string
+  sha256: string
+  size: number
+  origin: BuildFileOrigin
+  provider?: string
+  model?: string
+  agent_role?: string
+  task_id?: string
+  generated_at?: string
+}
+
+export interface BuildProvenance {
+  $schema: string
+  build_id: string
+  project_id?: number
+  mode: string
+  power_mode: string
+  provider_mode?: string
+  started_at: string
+  completed_at: string
+  origin: {
+    app_type?: string
+    template_id?: string
+    secondary_template_ids?: string[]
+    scaffold_id?: string
+    plan_source?: string
+    tech_stack?: Record<string, unknown>
+  }
+  models: { provider: string; model?: string; files: number }[]
+  files: BuildFileProvenance[]
+}
+
+export type ConsensusDecision = 'retry_same' | 'switch_provider' | 'spawn_solver' | 'abort'
+
+export interface ConsensusVote {
+  provider: string
+  decision: ConsensusDecision
+  rationale?: string
+  fallback?: boolean
+  weight: number
+  capability: number
+  accuracy: number
+  accuracy_samples: number
+}
+
+export interface ConsensusRound {
+  id: string
+  task_id: string
+  task_type: string
+  agent_role?: string
+  error: string
+  default_strategy: string
+  votes: ConsensusVote[]
+  tally: Partial<Record<ConsensusDecision, number>>
+  decision: ConsensusDecision
+  reason: 'weighted_majority' | 'no_majority' | 'budget_exceeded'
+  explanation: string
+  outcome: 'pending' | 'recovered' | 'failed' | 'inconclusive'
+  created_at: string
+  resolved_at?: string
+}
+
+export interface ProjectExport {
+  id: number
+  user_id: number
+  status: 'queued' | 'running' | 'completed' | 'failed' | 'expired'
+  projects: number
+  files: number
+  size_bytes: number
+  error?: string
+  completed_at?: string
+  expires_at?: string
+  created_at: string
+  updated_at: string
+}
+
+// ---------------------------------------------------------------------------
+// Onboarding types
+// ---------------------------------------------------------------------------
+
+export type OnboardingEvent =
+  | 'sample_project'
+  | 'ran_code'
+  | 'used_ai'
+  | 'deployed'
+  | 'created_project'
+  | 'manual'
+
+export interface OnboardingStep {
+  key: string
+  title: string
+  description?: string
+  event: OnboardingEvent
+  optional?: boolean
 }
 
-export interface ExternalDeploymentConfig {
-  project_id: number
-  provider: ExternalDeploymentProviderId
-  environment?: string
-  branch?: string
-  env_vars?: Record<string, string>
-  build_command?: string
-  output_dir?: string
-  install_cmd?: string
-  start_command?: string
-  framework?: string
-  node_version?: string
-  root_directory?: string
-  database?: ExternalDeploymentDatabaseConfig
+export interface OnboardingStepProgress extends OnboardingStep {
+  completed: boolean
+  completed_at?: string
 }
 
-export interface ExternalDeployment {
-  id: string
-  project_id: number
+export interface OnboardingState {
   user_id: number
-  provider: ExternalDeploymentProviderId
-  status: 'pending' | 'preparing' | 'building' | 'deploying' | 'live' | 'failed' | 'cancelled'
-  url?: string
-  preview_url?: string
-  environment: string
-  branch: string
-  commit_sha?: string
-  commit_msg?: string
-  build_time?: number
-  deploy_time?: number
-  total_time?: number
-  error_message?: string
-  config?: Record<string, unknown>
-  metadata?: Record<string, unknown>
-  started_at?: string
+  organization_id?: number
+  sample_project_id?: number
+  sample_template_id?: string
+  provision_error?: string
+  dismissed_at?: string
   completed_at?: string
   created_at: string
   updated_at: string
 }
 
-export interface ExternalDeploymentLog {
+export interface OnboardingProgress {
+  state: OnboardingState
+  steps: OnboardingStepProgress[]
+  completed_steps: number
+  total_steps: number
+  percent: number
+  // True once every non-optional step is done
+  completed: boolean
+  // True when the user's organization customized the checklist
+  customized: boolean
+}
+
+export interface OnboardingChecklistInput {
+  steps: OnboardingStep[]
+  sample_template_id?: string
+  skip_sample?: boolean
+}
+
+export interface OnboardingChecklist extends OnboardingChecklistInput {
   id: number
-  deployment_id: string
-  timestamp: string
-  level: 'debug' | 'info' | 'warn' | 'error'
-  message: string
-  phase?: string
-  metadata?: string
+  organization_id: number
+  updated_by: number
+  created_at: string
+  updated_at: string
 }
 
-export interface AlwaysOnStatus {
-  always_on: boolean
-  always_on_enabled: string | null
-  last_keep_alive: string | null
-  keep_alive_interval: number
-  sleep_after_minutes: number
-  restart_count: number
-  max_restarts: number
-  container_status: 'healthy' | 'unhealthy' | 'starting' | 'stopped'
-  uptime_seconds: number
+// ---------------------------------------------------------------------------
+// Referral types
+// ---------------------------------------------------------------------------
+
+export type ReferralStatus = 'pending' | 'rewarded' | 'capped' | 'flagged'
+
+export interface ReferralSummary {
+  id: number
+  // Masked, e.g. "g***e"
+  username: string
+  status: ReferralStatus
+  signed_up_at: string
+  converted_at?: string
+  reward_usd: number
 }
 
-export interface NativeDeploymentConfig {
-  subdomain?: string
-  port?: number
-  build_command?: string
-  start_command?: string
-  install_command?: string
-  framework?: string
-  node_version?: string
-  python_version?: string
-  go_version?: string
-  memory_limit?: number
-  cpu_limit?: number
-  health_check_path?: string
-  auto_scale?: boolean
-  min_instances?: number
-  max_instances?: number
-  env_vars?: Record<string, string>
-  always_on?: boolean
-  keep_alive_interval?: number
+export interface ReferralDashboard {
+  code: string
+  clicks: number
+  unique_clicks: number
+  signups: number
+  conversions: number
+  flagged: number
+  earned_credits_usd: number
+  reward_usd: number
+  referred_reward_usd: number
+  monthly_reward_cap: number
+  referrals: ReferralSummary[]
 }
 
-export interface NativeDeployment {
-  id: string
-  project_id: number
-  user_id: number
-  subdomain: string
-  url: string
-  preview_url?: string
-  status: 'pending' | 'provisioning' | 'building' | 'deploying' | 'running' | 'stopped' | 'failed' | 'deleted'
-  container_status: 'healthy' | 'unhealthy' | 'starting' | 'stopped'
-  error_message?: string
-  container_port: number
-  build_command?: string
-  start_command?: string
-  framework?: string
-  memory_limit: number
-  cpu_limit: number
-  auto_scale: boolean
-  min_instances: number
-  max_instances: number
-  current_instances: number
-  always_on: boolean
-  always_on_enabled?: string
-  last_keep_alive?: string
-  keep_alive_interval: number
-  total_requests: number
-  avg_response_time: number
-  uptime_seconds: number
+export interface ReferralDashboardResponse {
+  // Tracked link to share
+  link: string
+  signup_url: string
+  dashboard: ReferralDashboard
+}
+
+export type AbuseCaseStatus = 'review' | 'throttled' | 'suspended' | 'appealed' | 'cleared'
+
+export interface AbuseCase {
+  id: number
   created_at: string
   updated_at: string
-  deployed_at?: string
-  build_duration?: number
-  deploy_duration?: number
+  user_id: number
+  status: AbuseCaseStatus
+  enforcement: 'none' | 'throttle' | 'suspend'
+  score: number
+  reason: string
+  free_tier: boolean
+  appeal_message?: string
+  appealed_at?: string
+  reviewed_by?: number
+  reviewed_at?: string
+  review_note?: string
+  closed_at?: string
 }
 
-export interface DeploymentLog {
-  id: number
-  deployment_id: string
-  timestamp: string
-  level: 'debug' | 'info' | 'warn' | 'error'
-  source: string
-  message: string
-  metadata?: string
+export interface AbuseQueueItem extends AbuseCase {
+  username: string
+  email: string
 }
 
-export interface DeploymentMetrics {
-  total_requests: number
-  avg_response_time: number
-  uptime_seconds: number
-  bandwidth_used: number
-  current_instances: number
-  container_status: string
-  last_request_at?: string
-  last_health_check?: string
-  memory_limit: number
-  cpu_limit: number
+export interface AbuseSignal {
+  id: number
+  created_at: string
+  user_id: number
+  case_id?: number
+  execution_id?: string
+  kind: 'miner_binary' | 'mining_pool' | 'miner_output' | 'outbound_smtp' | 'sustained_cpu' | 'cpu_budget'
+  score: number
+  evidence: string
 }
 
-// ---------------------------------------------------------------------------
-// AI Code Review types (matching backend codereview.ReviewResponse)
-// ---------------------------------------------------------------------------
+export interface ReplaceRequest {
+  project_id: number
+  search: string
+  // With use_regex, $1 or ${name} refer to capture groups; otherwise literal
+  replace: string
+  case_sensitive?: boolean
+  whole_word?: boolean
+  use_regex?: boolean
+  file_types?: string[]
+  paths?: string[]
+}
 
-export interface CodeReviewFinding {
-  type: string       // bug, security, performance, style, best_practice
-  severity: string   // error, warning, info, hint
+export interface ReplaceMatch {
+  // Byte range "start-end", stable while the file is unchanged
+  id: string
   line: number
-  end_line: number
   column: number
-  end_column: number
-  message: string
-  suggestion: string
-  code: string
-  rule_id: string
+  matched: string
+  replacement: string
+  before: string
+  after: string
 }
 
-export interface CodeReviewMetrics {
-  total_lines: number
-  code_lines: number
-  comment_lines: number
-  blank_lines: number
-  complexity: number
-  security_issues: number
-  bug_risks: number
-  style_issues: number
+export interface ReplacePreview {
+  search: string
+  replace: string
+  file_count: number
+  match_count: number
+  truncated: boolean
+  files: Array<{
+    file_id: number
+    file_path: string
+    content_hash: string
+    match_count: number
+    matches: ReplaceMatch[]
+  }>
 }
 
-export interface CodeReviewResponse {
-  findings: CodeReviewFinding[]
-  summary: string
-  score: number
-  metrics: CodeReviewMetrics
-  suggestions: string[]
-  reviewed_at: string
-  duration_ms: number
+// Empty match_ids applies every match in the file
+export interface ReplaceSelection {
+  file_id: number
+  content_hash: string
+  match_ids?: string[]
 }
 
-// ---------------------------------------------------------------------------
-// Package Management types (matching backend packages.go)
-// ---------------------------------------------------------------------------
+export interface ReplaceConflict {
+  file_id: number
+  file_path?: string
+  reason: 'changed' | 'deleted'
+}
 
-export type PackageRegistry = 'npm' | 'pip' | 'go'
+export interface ReplaceApplyResult {
+  undo_token?: string
+  expires_at?: string
+  files_modified: number
+  total_replaces: number
+  files: Array<{ file_id: number; file_path: string; replacements: number; before_version_id: number; after_hash: string }>
+  conflicts?: ReplaceConflict[]
+}
 
-export interface PackageSearchResult {
+export interface ReplaceUndoResult {
+  files_restored: number
+  conflicts?: ReplaceConflict[]
+}
+
+export interface WorkspaceProjectResults {
+  project_id: number
+  project_name: string
+  organization_id?: number
+  total_matches: number
+  file_matches: number
+  truncated: boolean
+  files: Array<{
+    file_id: number
+    file_name: string
+    file_path: string
+    language: string
+    matches: Array<{ line_number: number; column_start: number; column_end: number; content: string }>
+  }>
+}
+
+export interface WorkspaceSearchResults {
+  query: string
+  total_matches: number
+  file_matches: number
+  projects_searched: number
+  projects_matched: number
+  // Projects with the most matches first
+  projects: WorkspaceProjectResults[]
+  // Set when some projects were not searched
+  truncated: boolean
+  duration: number
+}
+
+export type SymbolKind =
+  | 'function' | 'method' | 'class' | 'interface' | 'struct' | 'enum'
+  | 'trait' | 'type' | 'constant' | 'variable' | 'module'
+
+export interface ProjectSymbol {
+  id: number
+  project_id: number
+  file_id: number
+  file_path: string
   name: string
-  version: string
-  description: string
-  homepage?: string
-  repository?: string
-  license?: string
-  downloads?: number
-  keywords?: string[]
+  kind: SymbolKind
+  // Enclosing class, struct, trait, impl or module
+  container?: string
+  signature?: string
+  line: number
+  end_line: number
+  column: number
+  exported: boolean
+  language: string
 }
 
-export interface PackageInfo {
+export interface SymbolOutlineNode {
   name: string
-  version: string
-  description: string
-  homepage?: string
-  repository?: string
-  license?: string
-  author?: string
-  dependencies?: Record<string, string>
-  dev_dependencies?: Record<string, string>
-  versions?: string[]
-  readme?: string
+  kind: SymbolKind
+  signature?: string
+  line: number
+  end_line: number
+  column: number
+  exported: boolean
+  children?: SymbolOutlineNode[]
+}
+
+export interface SearchIndexStats {
+  // False unless the database is PostgreSQL; searches then scan file content
+  enabled: boolean
+  project_id?: number
+  files: number
+  indexed: number
+  pending: number
+  // Too large to index; always scanned
+  oversized: number
+  oldest_pending_at?: string
+  lag_seconds: number
+  last_refresh_at?: string
+  last_refresh_error?: string
+  healthy: boolean
+}
+
+// Zero keeps forever
+export interface RetentionPolicy {
+  version_days: number
+  versions_per_file: number
+  restore_point_days: number
+  build_days: number
+  exempt?: boolean
+}
+
+export interface RetentionOverrideInput {
+  scope: 'user' | 'project'
+  scope_id: number
+  // Omitted limits keep the plan default
+  version_days?: number
+  versions_per_file?: number
+  restore_point_days?: number
+  build_days?: number
+  exempt?: boolean
+  note?: string
+}
+
+export interface RetentionOverride extends RetentionOverrideInput {
+  id: number
+  created_at: string
+  updated_at: string
+  exempt: boolean
+  set_by_id: number
+}
+
+export interface RetentionRun {
+  id: number
+  started_at: string
+  finished_at?: string
+  dry_run: boolean
+  // Unset for the background job
+  triggered_by_id?: number
+  projects: number
+  versions_pruned: number
+  version_bytes: number
+  restore_points_pruned: number
+  restore_point_bytes: number
+  builds_pruned: number
+  build_bytes: number
+  error?: string
 }
 
-export interface InstalledPackage {
-  name: string
-  version: string
-  is_dev: boolean
-  type: PackageRegistry
+export interface RetentionReport extends RetentionRun {
+  reclaimed_bytes: number
+  project_reports: {
+    project_id: number
+    owner_id: number
+    plan: string
+    policy: RetentionPolicy
+    versions_pruned: number
+    version_bytes: number
+    restore_points_pruned: number
+    restore_point_bytes: number
+  }[]
 }
 
-export interface PackageSuggestion {
+export interface AbuseStatus {
+  allowed: boolean
+  code?: 'execution_suspended' | 'execution_throttled' | 'disposable_email_blocked'
+  reason?: string
+  // Run time cap while throttled; 0 when not throttled
+  max_timeout_seconds: number
+  case: AbuseCase | null
+  can_appeal: boolean
+  disposable_email_block: boolean
+}
+
+export interface ManagementToken {
+  id: number
+  created_at: string
+  user_id: number
   name: string
-  description: string
-  category: string
-  is_dev: boolean
+  // First characters of the token, for telling tokens apart
+  prefix: string
+  last_used_at?: string
+  expires_at?: string
+  revoked_at?: string
 }
 
-// ---------------------------------------------------------------------------
-// Debug System types (matching backend debugging handlers)
-// ---------------------------------------------------------------------------
+export interface CreatedManagementToken {
+  token: ManagementToken
+  secret: string
+}
 
-export type DebugSessionStatus = 'pending' | 'running' | 'paused' | 'completed' | 'error'
-export type DebugBreakpointType = 'line' | 'conditional' | 'logpoint' | 'exception' | 'function'
+export type EnvironmentName = 'development' | 'staging' | 'production'
 
-export interface DebugSession {
-  id: string
-  project_id: number
-  user_id: number
-  file_id: number
-  status: DebugSessionStatus
-  language: string
-  entry_point: string
-  working_directory: string
-  debug_port: number
-  devtools_url?: string
-  process_id?: number
-  error_message?: string
-  started_at?: string
-  ended_at?: string
-  current_line?: number
-  current_file?: string
+export interface EnvironmentConfig {
+  framework?: string
+  build_command?: string
+  install_command?: string
+  start_command?: string
+  output_dir?: string
+  node_version?: string
+  port?: number
+  health_check_path?: string
 }
 
-export interface DebugBreakpoint {
-  id: string
-  session_id: string
-  file_id: number
-  file_path: string
-  line: number
-  column: number
-  type: DebugBreakpointType
-  condition?: string
-  log_message?: string
-  hit_count: number
-  enabled: boolean
-  verified: boolean
-  breakpoint_id?: string
+export interface EnvironmentSpec {
+  // 'native' or a deploy provider name
+  target: string
+  config?: EnvironmentConfig
+  smoke_paths?: string[]
+  env_vars?: Record<string, string>
 }
 
-export interface DebugStackFrame {
-  id: string
-  index: number
-  function_name: string
-  file_path: string
-  line: number
-  column: number
-  script_id?: string
-  is_async: boolean
-  scopes?: DebugScope[]
-  local_vars?: Record<string, string>
+export interface SmokeResult {
+  path: string
+  status_code?: number
+  duration_ms: number
+  error?: string
+  passed: boolean
 }
 
-export interface DebugScope {
-  type: 'local' | 'closure' | 'global' | 'with' | 'catch' | 'block' | 'script'
-  name?: string
-  start_line?: number
-  end_line?: number
-  variables?: DebugVariable[]
+export interface EnvironmentRelease {
+  id: number
+  created_at: string
+  updated_at: string
+  project_id: number
+  environment: EnvironmentName
+  artifact_id: number
+  artifact_digest: string
+  user_id: number
+  target: string
+  deployment_id?: string
+  promoted_from_id?: number
+  status: 'deploying' | 'smoke_testing' | 'live' | 'failed' | 'smoke_failed' | 'superseded'
+  url?: string
+  smoke_results?: SmokeResult[]
+  error?: string
+  completed_at?: string
 }
 
-export interface DebugVariable {
-  name: string
-  value: string
-  type: string
-  object_id?: string
-  has_children: boolean
-  children?: DebugVariable[]
-  preview?: string
+export interface ProjectEnvironment {
+  id: number
+  created_at: string
+  updated_at: string
+  project_id: number
+  name: EnvironmentName
+  target: string
+  config: EnvironmentConfig
+  smoke_paths: string[]
+  // Values are write-only
+  env_var_keys: string[]
+  live_release_id?: number
+  live_release?: EnvironmentRelease | null
+  latest_release?: EnvironmentRelease | null
 }
 
-export interface DebugWatchExpression {
-  id: string
-  expression: string
-  value?: string
-  type?: string
-  error?: string
+export interface DockerfileResult {
+  stack: string
+  port: number
+  dockerfile: string
+  dockerignore: string
+  notes?: string[]
 }
 
-export interface DebugEvaluateResult {
-  value: string
-  type: string
-  object_id?: string
-  has_children: boolean
-  preview?: string
+export interface DockerizeOutcome extends DockerfileResult {
+  verified: boolean
+  saved: boolean
+  build_log?: string
+  duration_ms: number
+  skipped_files?: number
+}
+
+export interface DockerizeRun {
+  project_id: number
+  status: 'running' | 'succeeded' | 'failed'
   error?: string
+  outcome?: DockerizeOutcome
+  started_at: string
+  finished_at?: string
 }
 
-export interface DebugEvent {
-  type: DebugEventType
-  timestamp: string
-  data: any
+export interface ProjectServiceToken {
+  id: number
+  project_id: number
+  user_id: number
+  prefix: string
+  version: number
+  rotated_at: string
+  next_rotation_at: string
+  previous_expires_at?: string
+  last_used_at?: string
+  created_at: string
+  updated_at: string
 }
 
-export type DebugEventType =
-  | 'session_started'
-  | 'session_stopped'
-  | 'paused'
-  | 'resumed'
-  | 'stepping'
-  | 'breakpoint_hit'
-  | 'breakpoint_verified'
-  | 'breakpoint_added'
-  | 'breakpoint_removed'
-  | 'exception'
-  | 'output'
-  | 'error'
+export interface ProjectServiceTokenStatus {
+  enabled: boolean
+  token?: ProjectServiceToken
+  scopes: string[]
+  env_vars?: string[]
+}
+
+export interface ProjectServiceTokenUsage {
+  id: number
+  token_id: number
+  project_id: number
+  version: number
+  method: string
+  path: string
+  status: number
+  ip_address?: string
+  created_at: string
+}
 
 // ---------------------------------------------------------------------------
 // Hosting System types (*.apex.app native hosting)
@@ -3583,64 +6859,218 @@ export type DomainVerificationStatus =
   | 'verified'
   | 'failed'
 
-export type SSLStatus =
-  | 'pending'
-  | 'provisioning'
-  | 'active'
-  | 'expired'
-  | 'failed'
+export type SSLStatus =
+  | 'pending'
+  | 'provisioning'
+  | 'active'
+  | 'expired'
+  | 'failed'
+
+export interface HostingStatus {
+  project_id: number
+  deployment_id?: string
+  status: DeploymentStatus
+  url?: string
+  subdomain?: string
+  ssl_enabled: boolean
+  container_status?: 'healthy' | 'unhealthy' | 'starting' | 'stopped'
+  last_deployed_at?: string
+  build_duration_ms?: number
+  deploy_duration_ms?: number
+  error_message?: string
+  metrics?: {
+    total_requests: number
+    avg_response_time_ms: number
+    uptime_seconds: number
+    bandwidth_bytes: number
+  }
+  custom_domains?: CustomDomain[]
+}
+
+export interface QuickDeployConfig {
+  subdomain?: string
+  build_command?: string
+  start_command?: string
+  install_command?: string
+  port?: number
+  env_vars?: Record<string, string>
+  framework?: string
+  node_version?: string
+  python_version?: string
+  go_version?: string
+  always_on?: boolean
+  health_check_path?: string
+  memory_limit?: number
+  cpu_limit?: number
+}
+
+export interface CustomDomain {
+  id: number
+  project_id: number
+  domain: string
+  verification_status: DomainVerificationStatus
+  verification_token: string
+  ssl_status: SSLStatus
+  ssl_expires_at?: string
+  is_primary: boolean
+  created_at: string
+  verified_at?: string
+  last_checked_at?: string
+  error_message?: string
+}
+
+export type HostingWorkerStatus = 'running' | 'sleeping' | 'stopped' | 'failed'
+
+export interface HostingWorkerConfig {
+  name: string
+  command: string
+  replicas?: number
+  memory_limit?: number
+  cpu_limit?: number
+  // Forced on for Builder plans
+  scale_to_zero?: boolean
+  idle_timeout_minutes?: number
+  queue?: boolean
+}
+
+export interface HostingWorker {
+  id: number
+  project_id: number
+  deployment_id?: string
+  name: string
+  command: string
+  replicas: number
+  memory_limit: number
+  cpu_limit: number
+  scale_to_zero: boolean
+  idle_timeout_minutes: number
+  queue_enabled: boolean
+  queue_database_id?: number
+  status: HostingWorkerStatus
+  current_replicas: number
+  restart_count: number
+  started_at?: string
+  slept_at?: string
+  last_activity_at?: string
+  uptime_seconds: number
+  error_message?: string
+  created_at: string
+  updated_at: string
+}
+
+export type HostingErrorStatus = 'unresolved' | 'resolved' | 'ignored'
+
+export interface HostingErrorFrame {
+  filename: string
+  function?: string
+  line?: number
+  column?: number
+  in_app: boolean
+}
+
+export interface HostingErrorGroup {
+  id: number
+  project_id: number
+  deployment_id: string
+  fingerprint: string
+  type: string
+  message: string
+  culprit?: string
+  level: string
+  platform?: string
+  release?: string
+  status: HostingErrorStatus
+  event_count: number
+  first_seen: string
+  last_seen: string
+  created_at: string
+  updated_at: string
+}
+
+export interface HostingErrorEvent {
+  id: number
+  group_id: number
+  deployment_id: string
+  event_id: string
+  level: string
+  type: string
+  message: string
+  environment?: string
+  release?: string
+  url?: string
+  frames: HostingErrorFrame[] | null
+  tags?: Record<string, string>
+  timestamp: string
+  created_at: string
+}
+
+export type LogDrainType = 'https' | 'syslog' | 'datadog' | 'logtail'
+export type LogDrainSources = 'all' | 'hosting' | 'preview'
 
-export interface HostingStatus {
+export interface LogDrainInput {
+  name: string
+  type: LogDrainType
+  url?: string
+  token?: string
+  sources?: LogDrainSources
+  deployment_id?: string
+  enabled?: boolean
+}
+
+export interface LogDrain {
+  id: number
   project_id: number
+  user_id: number
   deployment_id?: string
-  status: DeploymentStatus
-  url?: string
-  subdomain?: string
-  ssl_enabled: boolean
-  container_status?: 'healthy' | 'unhealthy' | 'starting' | 'stopped'
-  last_deployed_at?: string
-  build_duration_ms?: number
-  deploy_duration_ms?: number
-  error_message?: string
-  metrics?: {
-    total_requests: number
-    avg_response_time_ms: number
-    uptime_seconds: number
-    bandwidth_bytes: number
-  }
-  custom_domains?: CustomDomain[]
+  name: string
+  type: LogDrainType
+  url: string
+  has_token: boolean
+  sources: LogDrainSources
+  enabled: boolean
+  status: 'pending' | 'healthy' | 'failing'
+  delivered_count: number
+  failed_count: number
+  dropped_count: number
+  last_delivery_at?: string
+  last_error_at?: string
+  last_error?: string
+  created_at: string
+  updated_at: string
 }
 
-export interface QuickDeployConfig {
-  subdomain?: string
-  build_command?: string
-  start_command?: string
-  install_command?: string
-  port?: number
-  env_vars?: Record<string, string>
-  framework?: string
-  node_version?: string
-  python_version?: string
-  go_version?: string
-  always_on?: boolean
-  health_check_path?: string
-  memory_limit?: number
-  cpu_limit?: number
+export interface SecurityHeaders {
+  content_security_policy?: string
+  csp_report_only?: boolean
+  strict_transport_security?: string
+  frame_ancestors?: string[]
+  permissions_policy?: string
+  referrer_policy?: string
+  disable_violation_reports?: boolean
 }
 
-export interface CustomDomain {
+export interface DeploymentSecurityHeaders {
+  deployment_id: string
+  framework: string
+  configured: SecurityHeaders | null
+  effective: SecurityHeaders
+  defaults: SecurityHeaders
+}
+
+export interface CSPViolation {
   id: number
   project_id: number
-  domain: string
-  verification_status: DomainVerificationStatus
-  verification_token: string
-  ssl_status: SSLStatus
-  ssl_expires_at?: string
-  is_primary: boolean
-  created_at: string
-  verified_at?: string
-  last_checked_at?: string
-  error_message?: string
+  deployment_id: string
+  directive: string
+  blocked_uri: string
+  document_uri?: string
+  source_file?: string
+  line_number?: number
+  sample?: string
+  disposition: 'enforce' | 'report'
+  count: number
+  first_seen: string
+  last_seen: string
 }
 
 export interface DeploymentEvent {
@@ -3664,6 +7094,7 @@ export interface PlanLimits {
   storage_bytes: number
   ai_requests: number
   execution_minutes: number
+  max_file_bytes: number
 }
 
 export interface UsageWarning {
@@ -3710,9 +7141,43 @@ export interface CurrentUsageData {
     }
   }
   warnings: UsageWarning[]
+  overrides?: ActiveQuotaOverride[]
   cached_at: string
 }
 
+export type QuotaOverrideSubject = 'user' | 'organization'
+export type QuotaOverrideMode = 'boost' | 'limit'
+export type QuotaOverrideStatus = 'active' | 'scheduled' | 'expired' | 'revoked'
+
+// An admin override reflected in the current limits
+export interface ActiveQuotaOverride {
+  id: number
+  source: QuotaOverrideSubject
+  usage_type: UsageType
+  mode: QuotaOverrideMode
+  amount: number
+  expires_at?: string
+}
+
+export interface QuotaOverride {
+  id: number
+  subject_type: QuotaOverrideSubject
+  subject_id: number
+  usage_type: UsageType
+  mode: QuotaOverrideMode
+  amount: number // -1 with mode "limit" means unlimited
+  reason: string
+  status: QuotaOverrideStatus
+  starts_at: string
+  expires_at?: string | null
+  created_by: number
+  created_at: string
+  expired_at?: string | null
+  revoked_at?: string | null
+  revoked_by?: number | null
+  revoke_note?: string
+}
+
 export interface UsageHistoryData {
   user_id: number
   days: number
@@ -3730,6 +7195,178 @@ export interface UsageHistoryData {
     ai_requests: number
     execution_minutes: number
   }>
+  months: number
+  periods: UsagePeriod[] // current period first, then closed periods newest first
+}
+
+export interface UsagePeriod {
+  subject_type: 'user' | 'organization'
+  subject_id: number
+  period: string
+  period_start: string
+  period_end: string
+  plan: string
+  projects: number
+  storage_bytes: number
+  ai_requests: number
+  execution_minutes: number
+  projects_limit: number
+  storage_limit: number
+  ai_requests_limit: number
+  execution_minutes_limit: number
+  current?: boolean
+}
+
+// Shared list query parameters (see "List Query Parameters" in API_CONTRACT.md)
+export interface ListParams {
+  limit?: number
+  cursor?: string
+  sort?: string // prefix with "-" for descending
+  order?: 'asc' | 'desc'
+  fields?: string // comma-separated
+}
+
+export interface PageInfo {
+  limit: number
+  has_more: boolean
+  next_cursor?: string
+  sort: string
+  order: 'asc' | 'desc'
+}
+
+/** Result of POST /projects/import/repository and the archive imports. */
+export interface ProjectImportResult {
+  project_id: number
+  project_name: string
+  language: string
+  framework: string
+  detected_stack: {
+    language: string
+    framework: string
+    package_manager: string
+    entry_point: string
+  }
+  file_count: number
+  skipped_files?: number
+  source?: 'github' | 'gitlab' | 'bitbucket' | 'archive' | 'upload'
+  status: string
+  message: string
+  import_duration_ms: number
+  repository_url: string
+  default_branch: string
+}
+
+/** Resumable upload session (POST /projects/:id/uploads). */
+export interface FileUpload {
+  id: string
+  project_id: number
+  user_id: number
+  path: string
+  mime_type: string
+  size: number
+  received: number
+  replace_file_id?: number
+  expires_at: string
+  created_at: string
+  updated_at: string
+}
+
+/** File left untouched by a git sync because it changed on both sides. */
+export interface GitSyncConflict {
+  id: number
+  project_id: number
+  path: string
+  kind: 'modified' | 'deleted_remotely' | 'deleted_locally'
+  base_sha?: string
+  local_sha?: string
+  remote_sha?: string
+  commit_sha: string
+  created_at: string
+  resolution?: 'ours' | 'theirs' | 'manual'
+  resolved_at?: string
+}
+
+export interface GitConflictHunk {
+  index: number
+  base_start: number
+  base_lines: number
+  merged_start: number
+  base: string
+  ours: string
+  theirs: string
+}
+
+export interface GitConflictDetail extends GitSyncConflict {
+  base: string
+  ours: string
+  theirs: string
+  merged: string
+  hunks: GitConflictHunk[]
+}
+
+export interface GitSyncStatus {
+  success: boolean
+  auto_sync: boolean
+  branch: string
+  status: 'idle' | 'syncing' | 'synced' | 'conflict' | 'error'
+  error?: string
+  last_sync: string
+  last_synced_sha?: string
+  conflicts: GitSyncConflict[]
+}
+
+export interface GitIssue {
+  number: number
+  title: string
+  body: string
+  state: string
+  author: string
+  labels?: string[]
+  url: string
+  comments?: { author: string; body: string; created_at: string }[]
+}
+
+export interface GitIssueBuild {
+  id: number
+  project_id: number
+  user_id: number
+  issue_number: number
+  issue_url: string
+  issue_title: string
+  status: 'completed' | 'no_changes' | 'failed'
+  summary?: string
+  changed_files: string[] | null
+  context_files: string[] | null
+  provider?: string
+  branch?: string
+  pull_request_number?: number
+  pull_request_url?: string
+  error?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface ProjectStorage {
+  project_id: number
+  name: string
+  trashed: boolean
+  file_count: number
+  bytes: number
+  trash_bytes: number
+  billable_bytes: number
+  percentage: number // share of total, not of the limit
+}
+
+export interface StorageBreakdown {
+  plan: string
+  total: number
+  limit: number
+  percentage: number
+  total_formatted: string
+  limit_formatted: string
+  trash_weight: number
+  projects: ProjectStorage[] // largest first
+  reconciled_at?: string
 }
 
 export interface UsageLimitsData {
@@ -4083,6 +7720,35 @@ export interface MobileSubmissionCreateResponse {
   store_readiness?: MobileStoreReadinessReport
 }
 
+export interface PreviewDiagnosticFrame {
+  function?: string
+  file: string
+  line: number
+  column: number
+  original?: { source: string; line: number; column: number; name?: string }
+}
+
+export interface PreviewDiagnostic {
+  id: number
+  kind: 'error' | 'unhandledrejection' | 'console'
+  level: 'error' | 'warn'
+  message: string
+  stack?: string
+  frames?: PreviewDiagnosticFrame[]
+  source?: string
+  page_url?: string
+  count: number
+  first_seen: string
+  last_seen: string
+}
+
+export interface PreviewDiagnosticsResponse {
+  success: boolean
+  diagnostics: PreviewDiagnostic[]
+  counts: { error: number; warn: number }
+  solver_ready: boolean
+}
+
 export interface MobileExpoWebPreviewResponse {
   success: boolean
   preview_level: 'expo_web' | string
@@ -4149,9 +7815,11 @@ export interface BuildInteractionState {
   waiting_for_user?: boolean
   paused?: boolean
   pause_reason?: string
+  parked_results?: number
   permission_rules?: BuildPermissionRule[]
   permission_requests?: BuildPermissionRequest[]
   approval_events?: BuildApprovalEvent[]
+  approval_requests?: BuildApprovalRequest[]
   attention_required?: boolean
 }
 
@@ -4273,6 +7941,185 @@ export interface BuildApproval {
   resolved_at?: string
 }
 
+export interface BuildApprovalGate {
+  checkpoint: 'plan' | 'deploy'
+  timeout_seconds?: number
+  on_timeout?: 'approve' | 'reject'
+}
+
+export interface BuildApprovalRequest {
+  id: string
+  checkpoint: 'plan' | 'deploy'
+  status: 'pending' | 'approved' | 'rejected' | 'timed_out'
+  title: string
+  summary?: string
+  on_timeout: 'approve' | 'reject'
+  plan_edited?: boolean
+  resolution_note?: string
+  resolved_by?: 'user' | 'timeout'
+  requested_at: string
+  expires_at: string
+  resolved_at?: string
+}
+
+export interface BuildPlanOutline {
+  plan_id: string
+  app_type: string
+  tech_stack: {
+    frontend: string
+    backend: string
+    database: string
+    styling: string
+    extras: string[] | null
+  }
+  features: Array<{ id: string; name: string; description: string; priority: number; dependencies?: string[] }> | null
+  files: Array<{ path: string; type: string; description: string; dependencies?: string[] }> | null
+  api_endpoints: Array<{ method: string; path: string; description: string; auth: boolean }> | null
+  data_models: Array<{ name: string; description: string }> | null
+  components: Array<{ name: string; description: string; type: string }> | null
+  editable: boolean
+  approval_request_id?: string
+}
+
+export interface BuildComparisonSide {
+  build_id: string
+  project_id?: number
+  mode: string
+  power_mode: string
+  files_count: number
+  total_cost: number
+  duration_ms: number
+  ai_calls: number
+  input_tokens: number
+  output_tokens: number
+  completed_at?: string
+  validation: {
+    status: string
+    error?: string
+    quality_gate_status?: string
+    quality_gate_stage?: string
+    compile_validation_passed: boolean
+    compile_validation_attempts?: number
+    compile_validation_repairs?: number
+    blockers?: number
+  }
+}
+
+export interface BuildFileDiff {
+  path: string
+  status: 'added' | 'removed' | 'changed'
+  lines_added: number
+  lines_removed: number
+  diff?: string
+  truncated?: boolean
+}
+
+export interface BuildComparison {
+  matched_by: 'project' | 'description'
+  base: BuildComparisonSide
+  head: BuildComparisonSide
+  summary: {
+    added: number
+    removed: number
+    changed: number
+    unchanged: number
+    lines_added: number
+    lines_removed: number
+  }
+  cost: {
+    cost_delta_usd: number
+    duration_delta_ms: number
+    input_tokens_delta: number
+    output_tokens_delta: number
+    cheaper_build_id?: string
+    faster_build_id?: string
+  }
+  files: BuildFileDiff[]
+  validated_build_ids: string[]
+}
+
+export interface ProjectInstructionsResponse {
+  instructions: {
+    project_id: number
+    path: string
+    project: string
+    project_truncated?: boolean
+    project_allowed: boolean
+    organization_id?: number
+    organization?: string
+  }
+  effective_prompt: string
+  max_project_bytes: number
+  max_organization_bytes: number
+}
+
+export interface AgentProfile {
+  role: string
+  name?: string
+  instructions?: string
+  preferred_provider?: string
+  constraints?: string[]
+  requires_pipeline?: number
+  source?: string
+}
+
+export interface AgentListing {
+  id: number
+  slug: string
+  name: string
+  description: string
+  role: string
+  tags?: string[]
+  author_id: number
+  latest_version?: string
+  status: 'active' | 'disabled'
+  installs: number
+  rating: number
+  rating_count: number
+  created_at: string
+  updated_at: string
+}
+
+export interface AgentListingVersion {
+  id: number
+  listing_id: number
+  version: string
+  config: AgentProfile
+  changelog?: string
+  status: 'pending' | 'approved' | 'rejected'
+  flags?: string[]
+  moderation_note?: string
+  reviewed_by?: number
+  reviewed_at?: string
+  created_at: string
+}
+
+export interface InstalledMarketplaceAgent {
+  id: number
+  user_id: number
+  listing_id: number
+  pinned_version?: string
+  enabled: boolean
+  listing: AgentListing
+  resolved_version?: string
+  compatible: boolean
+  compatibility_issues?: string[]
+}
+
+export interface BuildPlanEdit {
+  tech_stack?: {
+    frontend?: string
+    backend?: string
+    database?: string
+    styling?: string
+    extras?: string[]
+  }
+  remove_features?: string[]
+  remove_files?: string[]
+  rename_files?: Record<string, string>
+  remove_endpoints?: string[]
+}
+
 export interface BuildApprovalEvent {
   id: string
   kind: string
@@ -4739,6 +8586,102 @@ export interface CompletedBuildDetail extends CompletedBuildSummary {
   failure_fingerprints?: BuildFailureFingerprintState[]
   historical_learning?: BuildLearningSummaryState
   truth_by_surface?: Record<string, string[]>
+  file_confidence?: FileConfidenceReport
+  cost_optimization?: CostOptimizationReport
+  environment_drift?: EnvironmentLockDrift[]
+  env_var_check?: EnvVarCheck
+}
+
+export interface CodingStandardsPatternRule {
+  name: string
+  pattern: string
+  files?: string
+  message?: string
+  severity?: 'error' | 'warning'
+}
+
+export interface CodingStandardsPolicy {
+  style_guide?: string
+  banned_libraries?: string[]
+  required_patterns?: CodingStandardsPatternRule[]
+  forbidden_patterns?: CodingStandardsPatternRule[]
+  sources?: string[]
+}
+
+export interface CodingStandardsViolation {
+  kind: 'banned_library' | 'required_pattern' | 'forbidden_pattern'
+  rule: string
+  path?: string
+  line?: number
+  message: string
+  severity: 'error' | 'warning'
+}
+
+export interface CodingStandardsReport {
+  violations: CodingStandardsViolation[]
+  errors: number
+  warnings: number
+  truncated?: boolean
+}
+
+export type FileRiskLevel = 'low' | 'medium' | 'high'
+
+export interface FileRiskSignal {
+  kind: 'verification' | 'lint' | 'reviewer' | 'truncation' | 'repair'
+  detail: string
+  penalty: number
+}
+
+export interface FileConfidence {
+  path: string
+  score: number
+  risk: FileRiskLevel
+  signals?: FileRiskSignal[]
+}
+
+export interface FileConfidenceReport {
+  files: FileConfidence[]
+  needs_attention: number
+  average_score: number
+  generated_at: string
+}
+
+export interface CostOptimizationAttempt {
+  task_id: string
+  task_type: string
+  agent_role: string
+  provider: string
+  model: string
+  tier: 'fast' | 'balanced' | 'max'
+  reason: 'cheapest_proven' | 'no_success_history' | 'escalated_after_failure'
+  projected_cost_usd: number
+  actual_cost_usd: number
+  outcome: 'pending' | 'succeeded' | 'failed' | 'unresolved'
+  created_at: string
+}
+
+export interface CostOptimizationReport {
+  total_attempts: number
+  escalations: number
+  tier_calls: Partial<Record<'fast' | 'balanced' | 'max', number>>
+  projected_cost_usd: number
+  actual_cost_usd: number
+  escalation_cost_usd: number
+  variance_usd: number
+  attempts: CostOptimizationAttempt[]
+  finalized_at?: string
+}
+
+export interface OrganizationCodingStandardsResponse {
+  success: boolean
+  coding_standards?: {
+    organization_id: number
+    policy: CodingStandardsPolicy
+    updated_by?: number
+    updated_at?: string
+  }
+  prompt_preview?: string
+  error?: string
 }
 
 // Create singleton instance
@@ -61,8 +61,11 @@ export interface Project {
   framework?: string
   owner_id: number
   owner?: User
+  organization_id?: number
   is_public: boolean
   is_archived: boolean
+  archived_at?: string
+  archive_size_bytes?: number
   root_directory: string
   entry_point?: string
   environment?: Record<string, any>
@@ -103,9 +106,10 @@ export interface File {
   name: string
   type: 'file' | 'directory'
   mime_type?: string
-  content: string
+  content: string // empty for binary files; fetch /files/:id/raw
   size: number
   hash?: string
+  is_binary?: boolean
   version: number
   last_edit_by?: number
   last_editor?: User
@@ -125,6 +129,7 @@ export interface AIRequest {
   project?: Project
   provider: AIProvider
   capability: AICapability
+  region?: string
   prompt: string
   code?: string
   language?: string
@@ -203,6 +208,79 @@ export interface ExecutionResult {
   timed_out?: boolean
   command?: string
   sandbox_type?: string
+  environment_lock?: EnvironmentLockCheck | null
+  artifacts?: ExecutionArtifact[]
+}
+
+// ExecutionArtifact is a file a project run created or modified
+export interface ExecutionArtifact {
+  id: number
+  execution_id: string
+  project_id: number
+  path: string
+  status: 'created' | 'modified'
+  size: number
+  content?: string
+  hash?: string
+  is_binary?: boolean
+  skip_reason?: 'binary' | 'too_large' | 'limit_reached' | 'unreadable'
+  applied_at?: string
+  created_at: string
+}
+
+// EnvironmentLock is the content of a project's apex.lock
+export interface EnvironmentLock {
+  lockfile_version: number
+  generated_at: string
+  generated_by: 'build' | 'preview' | 'execution'
+  languages: { name: string; version: string; source: string }[]
+  system_packages: { manager: string; name: string; version?: string }[]
+  env_vars: string[]
+  services: { name: string; image?: string; source: string }[]
+}
+
+export interface EnvironmentLockDrift {
+  kind: 'language' | 'system_package' | 'env_var' | 'service'
+  name: string
+  change: 'added' | 'removed' | 'changed'
+  locked?: string
+  current?: string
+}
+
+export interface EnvironmentLockCheck {
+  status: 'ok' | 'drift' | 'created' | 'invalid'
+  lock?: EnvironmentLock
+  drift?: EnvironmentLockDrift[]
+  warning?: string
+}
+
+// A variable the code reads that one or more environments lack. Tiers are
+// "workspace" (previews and runs) and the pipeline environments.
+export interface MissingEnvVar {
+  name: string
+  files: string[]
+  missing_in: string[]
+  placeholder?: boolean
+  action: 'create_placeholder_secret' | 'set_secret_value' | 'set_environment_variable'
+  message: string
+}
+
+export interface EnvVarCheck {
+  required: string[]
+  tiers: string[]
+  missing: MissingEnvVar[]
+  checked_at: string
+  warning?: string
+}
+
+export interface EnvPlaceholderSecret {
+  id: number
+  name: string
+  description?: string
+  type: string
+  project_id?: number
+  created_at: string
+  updated_at: string
 }
 
 export interface CollabRoom {
@@ -259,6 +337,8 @@ export interface LoginRequest {
   username?: string
   email?: string
   password: string
+  /** CAPTCHA widget response, sent after the server answers captcha_required */
+  captcha_token?: string
 }
 
 export interface RegisterRequest {
@@ -267,6 +347,8 @@ export interface RegisterRequest {
   password: string
   full_name?: string
   accept_legal_terms: boolean
+  // Code from a referral link; the API also reads it from the link cookie
+  referral_code?: string
 }
 
 export interface TokenResponse {
@@ -593,6 +675,7 @@ export interface ProjectStats {
   star_count: number
   fork_count: number
   view_count: number
+  embed_view_count?: number
   comment_count: number
   trend_score: number
   updated_at: string
@@ -608,6 +691,36 @@ export interface UserStats {
   updated_at: string
 }
 
+export interface PublicFileNode {
+  path: string
+  name: string
+  type: 'file' | 'directory'
+  size?: number
+  language?: string
+  is_binary?: boolean
+  children?: PublicFileNode[]
+}
+
+export interface PublicFile {
+  path: string
+  name: string
+  size: number
+  language: string
+  mime_type?: string
+  line_count: number
+  is_binary: boolean
+  truncated: boolean
+  content: string
+  updated_at: string
+}
+
+export interface ProjectShareLinks {
+  cover_url: string
+  share_page_url: string
+  og_image_url: string
+  badges: Record<'build' | 'deploy', { url: string; markdown: string }>
+}
+
 export interface ProjectWithStats extends Project {
   stats?: ProjectStats
   is_starred?: boolean
@@ -680,6 +793,23 @@ export interface FileVersion {
   file_name: string
   is_pinned: boolean
   is_auto_save: boolean
+  // Protected tagged versions can only be restored or deleted by project admins
+  tag?: string
+  is_protected: boolean
+  tagged_by_id?: number
+  tagged_at?: string
+  created_at: string
+}
+
+export interface FileVersionTag {
+  version_id: number
+  file_id: number
+  file_path: string
+  version: number
+  tag: string
+  is_protected: boolean
+  tagged_by_id?: number
+  tagged_at?: string
   created_at: string
 }
 
@@ -799,6 +929,53 @@ export interface ColumnInfo {
   is_primary_key: boolean
 }
 
+// Managed Redis browser types. Keys and channels are relative to the
+// database's namespace prefix.
+
+export interface RedisKey {
+  key: string
+  type: string
+  ttl_seconds: number // -1 when the key never expires
+}
+
+export interface RedisKeyPage {
+  keys: RedisKey[]
+  next_cursor: number // 0 when the scan is done
+}
+
+export interface RedisQueue {
+  key: string
+  type: 'list' | 'zset' | 'stream'
+  length: number
+}
+
+export interface RedisQueueReport {
+  queues: RedisQueue[]
+  sampled: boolean
+}
+
+export interface RedisChannel {
+  name: string
+  subscribers: number
+}
+
+export interface RedisMessage {
+  channel: string
+  payload: string
+  truncated?: boolean
+  received_at: string
+}
+
+export interface RedisMemoryStats {
+  keys: number
+  memory_bytes: number
+  keys_by_type: Record<string, number>
+  largest_keys: { key: string; type: string; bytes: number }[]
+  limit_bytes: number
+  sampled: boolean
+  eviction_policy?: string
+}
+
 // Code Comments Types (Replit parity feature)
 
 export interface CodeComment {
@@ -818,12 +995,28 @@ export interface CodeComment {
   resolved_at?: string
   resolved_by_id?: number
   reactions?: Record<string, number[]>
+  suggestion?: string // replacement text for start_line..end_line
+  suggestion_applied_at?: string
+  suggestion_applied_by_id?: number
+  assignee_id?: number
+  review_state?: CodeCommentReviewState
+  reviewer_id?: number
+  mentions?: number[]
   replies?: CodeComment[]
   reply_count: number
   created_at: string
   updated_at: string
 }
 
+export type CodeCommentReviewState = 'requested' | 'approved' | 'changes_requested'
+
+export interface CodeCommentFilters {
+  status?: 'open' | 'resolved' | 'all'
+  assigned?: 'me' | 'none' | number
+  review?: CodeCommentReviewState
+  reviewer?: 'me'
+}
+
 export interface CodeCommentThread {
   thread_id: string
   file_id: number
@@ -847,6 +1040,7 @@ export interface CreateCommentRequest {
   content: string
   parent_id?: number
   thread_id?: string
+  suggestion?: string
 }
 
 export interface UpdateCommentRequest {
@@ -872,6 +1066,8 @@ export interface EnvironmentConfig {
   dev_packages: PackageDependency[]
   system: string[]
   env_vars: Record<string, string>
+  preview_exclude_env?: string[]
+  preview_proxy?: { preserve_prefix?: boolean; keep_cookies?: boolean }
   build_command?: string
   start_command?: string
   install_command?: string
@@ -1013,6 +1209,9 @@ export interface CompletionResponse {
   processing_time_ms: number
   cached_hit: boolean
   usage?: CompletionUsage
+  fim?: boolean
+  superseded?: boolean
+  fast?: boolean
 }
 
 export interface RecentEdit {
@@ -1036,6 +1235,15 @@ export interface CompletionContext {
   related_files?: RelatedFile[]
   framework?: string
   dependencies?: Record<string, string>
+  definitions?: SymbolDefinition[]
+}
+
+export interface SymbolDefinition {
+  name: string
+  kind: string
+  signature?: string
+  path: string
+  line: number
 }
 
 export interface CompletionRequest {
@@ -1054,6 +1262,47 @@ export interface CompletionRequest {
   stop_tokens?: string[]
 }
 
+export type CompletionOutcome = 'accepted' | 'partial' | 'rejected'
+
+export interface ModelAcceptance {
+  language: string
+  provider: string
+  model: string
+  shown: number
+  accepted: number
+  partial: number
+  rejected: number
+  accepted_chars: number
+  acceptance_rate: number
+}
+
+export interface CompletionAnalytics {
+  from: string
+  to: string
+  rows: ModelAcceptance[]
+  totals: ModelAcceptance
+  preferred?: Record<string, { provider: string; model: string } | null>
+}
+
+export type CompletionMode = 'auto' | 'fast' | 'quality'
+
+export interface CompletionSettings {
+  mode: CompletionMode
+  fast_provider?: string
+  fast_model?: string
+  latency_target_ms: number
+  updated_at?: string
+}
+
+export interface FastModelStatus {
+  provider: string
+  model: string
+  avg_latency_ms: number
+  samples: number
+  failures: number
+  skipped_until?: string
+}
+
 export interface CompletionStats {
   total_requests: number
   avg_latency_ms: number
@@ -1109,6 +1358,8 @@ export interface Organization {
   custom_branding_enabled: boolean
   audit_log_retention_days: number
   data_retention_days: number
+  ai_instructions?: string
+  allow_project_ai_instructions: boolean
   members?: OrganizationMember[]
   roles?: Role[]
   audit_logs?: AuditLog[]
@@ -0,0 +1,51 @@
+terraform {
+  required_providers {
+    apex = {
+      source = "apex-build/apex"
+    }
+  }
+}
+
+# Reads APEX_ENDPOINT and APEX_MANAGEMENT_TOKEN when not set here
+provider "apex" {}
+
+resource "apex_organization" "acme" {
+  slug          = "acme"
+  name          = "Acme Inc"
+  billing_email = "billing@acme.example"
+}
+
+resource "apex_role" "developer" {
+  organization = apex_organization.acme.slug
+  name         = "Developer"
+  description  = "Builds and deploys projects"
+  permissions  = ["projects:read", "projects:create", "projects:update"]
+  is_default   = true
+}
+
+resource "apex_organization_member" "grace" {
+  organization = apex_organization.acme.slug
+  email        = "grace@acme.example"
+  role         = apex_role.developer.name
+}
+
+resource "apex_organization_quotas" "acme" {
+  organization    = apex_organization.acme.slug
+  max_members     = 10
+  max_projects    = 50
+  max_storage_gb  = 25
+  max_ai_requests = 5000
+}
+
+variable "webhook_secret" {
+  type      = string
+  sensitive = true
+}
+
+resource "apex_webhook" "membership" {
+  organization = apex_organization.acme.slug
+  webhook_id   = "membership-sync"
+  url          = "https://hooks.acme.example/apex"
+  events       = ["member_added", "member_removed", "member_role_changed"]
+  secret       = var.webhook_secret
+}
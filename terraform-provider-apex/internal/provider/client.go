@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned for 404s so resources can drop themselves from state
+var errNotFound = errors.New("not found")
+
+// Client calls the APEX.BUILD management API
+type Client struct {
+	endpoint string
+	token    string
+	http     *http.Client
+	agent    string
+}
+
+// NewClient creates a client for an API endpoint such as
+// https://api.apex-build.dev/api/v1
+func NewClient(endpoint, token, version string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/manage",
+		token:    token,
+		http:     &http.Client{Timeout: 30 * time.Second},
+		agent:    "terraform-provider-apex/" + version,
+	}
+}
+
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+	Created bool            `json:"created"`
+}
+
+// orgPath builds an escaped path under an organization
+func orgPath(org string, parts ...string) string {
+	path := "/organizations/" + url.PathEscape(org)
+	for _, part := range parts {
+		path += "/" + url.PathEscape(part)
+	}
+	return path
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.agent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method != http.MethodPut {
+		return errNotFound
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return fmt.Errorf("%s %s: unexpected %d response", method, path, resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 || !env.Success {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, env.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// Get reads a resource into out; missing resources return errNotFound
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Put applies a resource spec and decodes the resulting resource
+func (c *Client) Put(ctx context.Context, path string, spec, out interface{}) error {
+	return c.do(ctx, http.MethodPut, path, spec, out)
+}
+
+// Delete deletes a resource; deleting a missing resource succeeds
+func (c *Client) Delete(ctx context.Context, path string) error {
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil && !errors.Is(err, errNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Organization mirrors management.OrganizationResource
+type Organization struct {
+	ExternalID       string `json:"external_id,omitempty"`
+	ID               int64  `json:"id,omitempty"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	Website          string `json:"website"`
+	BillingEmail     string `json:"billing_email"`
+	SubscriptionType string `json:"subscription_type,omitempty"`
+}
+
+// Member mirrors management.MemberResource
+type Member struct {
+	ExternalID string `json:"external_id,omitempty"`
+	UserID     int64  `json:"user_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Role       string `json:"role"`
+	Status     string `json:"status,omitempty"`
+}
+
+// Role mirrors management.RoleResource
+type Role struct {
+	ExternalID  string   `json:"external_id,omitempty"`
+	ID          int64    `json:"id,omitempty"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	IsDefault   bool     `json:"is_default"`
+}
+
+// Quotas mirrors management.Quotas
+type Quotas struct {
+	MaxMembers    int64   `json:"max_members"`
+	MaxProjects   int64   `json:"max_projects"`
+	MaxStorageGB  float64 `json:"max_storage_gb"`
+	MaxAIRequests int64   `json:"max_ai_requests"`
+}
+
+// Webhook mirrors management.Webhook and management.WebhookSpec
+type Webhook struct {
+	ExternalID string   `json:"external_id,omitempty"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events"`
+	Secret     string   `json:"secret,omitempty"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
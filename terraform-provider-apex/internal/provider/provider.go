@@ -0,0 +1,111 @@
+// Package provider implements the apex Terraform provider
+package provider
+
+import (
+	"context"
+	"os"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const defaultEndpoint = "https://api.apex-build.dev/api/v1"
+
+var _ provider.Provider = (*apexProvider)(nil)
+
+type apexProvider struct {
+	version string
+}
+
+type providerModel struct {
+	Endpoint types.String `tfsdk:"endpoint"`
+	Token    types.String `tfsdk:"token"`
+}
+
+// New returns a provider factory for providerserver.Serve
+func New(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &apexProvider{version: version}
+	}
+}
+
+func (p *apexProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "apex"
+	resp.Version = p.version
+}
+
+func (p *apexProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manage APEX.BUILD organizations, members, roles, quotas and webhooks.",
+		Attributes: map[string]schema.Attribute{
+			"endpoint": schema.StringAttribute{
+				Description: "API base URL, e.g. https://api.apex-build.dev/api/v1. Defaults to $APEX_ENDPOINT, then the hosted API.",
+				Optional:    true,
+			},
+			"token": schema.StringAttribute{
+				Description: "Management token (apx_mgmt_...) created under Settings. Defaults to $APEX_MANAGEMENT_TOKEN.",
+				Optional:    true,
+				Sensitive:   true,
+			},
+		},
+	}
+}
+
+func (p *apexProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var config providerModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	endpoint := os.Getenv("APEX_ENDPOINT")
+	if !config.Endpoint.IsNull() {
+		endpoint = config.Endpoint.ValueString()
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	token := os.Getenv("APEX_MANAGEMENT_TOKEN")
+	if !config.Token.IsNull() {
+		token = config.Token.ValueString()
+	}
+	if token == "" {
+		resp.Diagnostics.AddAttributeError(path.Root("token"), "Missing management token",
+			"Set the token attribute or APEX_MANAGEMENT_TOKEN to a management token.")
+		return
+	}
+
+	client := NewClient(endpoint, token, p.version)
+	resp.ResourceData = client
+	resp.DataSourceData = client
+}
+
+func (p *apexProvider) Resources(context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		newOrganizationResource,
+		newMemberResource,
+		newRoleResource,
+		newQuotasResource,
+		newWebhookResource,
+	}
+}
+
+func (p *apexProvider) DataSources(context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+// clientFrom extracts the configured client in a resource's Configure
+func clientFrom(data any, diags interface{ AddError(string, string) }) *Client {
+	if data == nil {
+		return nil // provider not configured yet
+	}
+	client, ok := data.(*Client)
+	if !ok {
+		diags.AddError("Unexpected provider data", "The apex provider was not configured correctly.")
+	}
+	return client
+}
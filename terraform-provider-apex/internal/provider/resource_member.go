@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.ResourceWithConfigure   = (*memberResource)(nil)
+	_ resource.ResourceWithImportState = (*memberResource)(nil)
+)
+
+type memberResource struct {
+	client *Client
+}
+
+type memberModel struct {
+	Organization types.String `tfsdk:"organization"`
+	Email        types.String `tfsdk:"email"`
+	Role         types.String `tfsdk:"role"`
+	UserID       types.Int64  `tfsdk:"user_id"`
+	Username     types.String `tfsdk:"username"`
+}
+
+func newMemberResource() resource.Resource {
+	return &memberResource{}
+}
+
+func (r *memberResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_organization_member"
+}
+
+func (r *memberResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	resp.Schema = schema.Schema{
+		Description: "A member of an organization. The email must belong to an existing APEX.BUILD account.",
+		Attributes: map[string]schema.Attribute{
+			"organization": schema.StringAttribute{Description: "Organization slug.", Required: true, PlanModifiers: replace},
+			"email":        schema.StringAttribute{Description: "Member email (lowercase).", Required: true, PlanModifiers: replace},
+			"role":         schema.StringAttribute{Description: "Role name, e.g. Owner or an apex_role name.", Required: true},
+			"user_id": schema.Int64Attribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()},
+			},
+			"username": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *memberResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = clientFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (m *memberModel) path() string {
+	return orgPath(m.Organization.ValueString(), "members", m.Email.ValueString())
+}
+
+func (m *memberModel) fill(member *Member) {
+	m.Role = types.StringValue(member.Role)
+	m.UserID = types.Int64Value(member.UserID)
+	m.Username = types.StringValue(member.Username)
+}
+
+func (r *memberResource) apply(ctx context.Context, plan *memberModel) error {
+	var member Member
+	err := r.client.Put(ctx, plan.path(), Member{Role: plan.Role.ValueString()}, &member)
+	if err == nil {
+		plan.fill(&member)
+	}
+	return err
+}
+
+func (r *memberResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan memberModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply organization member", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *memberResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state memberModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var member Member
+	err := r.client.Get(ctx, state.path(), &member)
+	if errors.Is(err, errNotFound) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read organization member", err.Error())
+		return
+	}
+	state.fill(&member)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *memberResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan memberModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply organization member", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *memberResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state memberModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.Delete(ctx, state.path()); err != nil {
+		resp.Diagnostics.AddError("Failed to remove organization member", err.Error())
+	}
+}
+
+// ImportState accepts "<organization>/<email>"
+func (r *memberResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	org, email, ok := strings.Cut(req.ID, "/")
+	if !ok || org == "" || email == "" {
+		resp.Diagnostics.AddError("Invalid import ID", "Expected <organization>/<email>.")
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("organization"), org)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("email"), strings.ToLower(email))...)
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.ResourceWithConfigure   = (*organizationResource)(nil)
+	_ resource.ResourceWithImportState = (*organizationResource)(nil)
+)
+
+type organizationResource struct {
+	client *Client
+}
+
+type organizationModel struct {
+	Slug             types.String `tfsdk:"slug"`
+	ID               types.Int64  `tfsdk:"id"`
+	Name             types.String `tfsdk:"name"`
+	Description      types.String `tfsdk:"description"`
+	Website          types.String `tfsdk:"website"`
+	BillingEmail     types.String `tfsdk:"billing_email"`
+	SubscriptionType types.String `tfsdk:"subscription_type"`
+}
+
+func newOrganizationResource() resource.Resource {
+	return &organizationResource{}
+}
+
+func (r *organizationResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_organization"
+}
+
+func (r *organizationResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "An organization. Creating one makes the token's user its Owner. " +
+			"Destroying it only removes it from state; delete organizations from the console.",
+		Attributes: map[string]schema.Attribute{
+			"slug": schema.StringAttribute{
+				Description:   "Stable external ID: 2-63 lowercase letters, digits or hyphens.",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"id": schema.Int64Attribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()},
+			},
+			"name":          schema.StringAttribute{Required: true},
+			"description":   schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("")},
+			"website":       schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("")},
+			"billing_email": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("")},
+			"subscription_type": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *organizationResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = clientFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (m *organizationModel) fill(org *Organization) {
+	m.ID = types.Int64Value(org.ID)
+	m.Name = types.StringValue(org.Name)
+	m.Description = types.StringValue(org.Description)
+	m.Website = types.StringValue(org.Website)
+	m.BillingEmail = types.StringValue(org.BillingEmail)
+	m.SubscriptionType = types.StringValue(org.SubscriptionType)
+}
+
+func (r *organizationResource) apply(ctx context.Context, plan *organizationModel) error {
+	var org Organization
+	err := r.client.Put(ctx, orgPath(plan.Slug.ValueString()), Organization{
+		Name:         plan.Name.ValueString(),
+		Description:  plan.Description.ValueString(),
+		Website:      plan.Website.ValueString(),
+		BillingEmail: plan.BillingEmail.ValueString(),
+	}, &org)
+	if err == nil {
+		plan.fill(&org)
+	}
+	return err
+}
+
+func (r *organizationResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan organizationModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply organization", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *organizationResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state organizationModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var org Organization
+	err := r.client.Get(ctx, orgPath(state.Slug.ValueString()), &org)
+	if errors.Is(err, errNotFound) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read organization", err.Error())
+		return
+	}
+	state.fill(&org)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *organizationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan organizationModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply organization", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *organizationResource) Delete(_ context.Context, _ resource.DeleteRequest, resp *resource.DeleteResponse) {
+	resp.Diagnostics.AddWarning("Organization not deleted",
+		"The management API does not delete organizations. It was removed from Terraform state; delete it from the console if intended.")
+}
+
+func (r *organizationResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("slug"), req, resp)
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.ResourceWithConfigure   = (*quotasResource)(nil)
+	_ resource.ResourceWithImportState = (*quotasResource)(nil)
+)
+
+type quotasResource struct {
+	client *Client
+}
+
+type quotasModel struct {
+	Organization  types.String  `tfsdk:"organization"`
+	MaxMembers    types.Int64   `tfsdk:"max_members"`
+	MaxProjects   types.Int64   `tfsdk:"max_projects"`
+	MaxStorageGB  types.Float64 `tfsdk:"max_storage_gb"`
+	MaxAIRequests types.Int64   `tfsdk:"max_ai_requests"`
+}
+
+func newQuotasResource() resource.Resource {
+	return &quotasResource{}
+}
+
+func (r *quotasResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_organization_quotas"
+}
+
+func (r *quotasResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "An organization's limits. Values above the organization's plan need a platform admin. " +
+			"Destroying this resource leaves the current limits in place.",
+		Attributes: map[string]schema.Attribute{
+			"organization": schema.StringAttribute{
+				Description:   "Organization slug.",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"max_members":     schema.Int64Attribute{Required: true},
+			"max_projects":    schema.Int64Attribute{Required: true},
+			"max_storage_gb":  schema.Float64Attribute{Required: true},
+			"max_ai_requests": schema.Int64Attribute{Required: true},
+		},
+	}
+}
+
+func (r *quotasResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = clientFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (m *quotasModel) fill(q *Quotas) {
+	m.MaxMembers = types.Int64Value(q.MaxMembers)
+	m.MaxProjects = types.Int64Value(q.MaxProjects)
+	m.MaxStorageGB = types.Float64Value(q.MaxStorageGB)
+	m.MaxAIRequests = types.Int64Value(q.MaxAIRequests)
+}
+
+func (r *quotasResource) apply(ctx context.Context, plan *quotasModel) error {
+	var quotas Quotas
+	err := r.client.Put(ctx, orgPath(plan.Organization.ValueString(), "quotas"), Quotas{
+		MaxMembers:    plan.MaxMembers.ValueInt64(),
+		MaxProjects:   plan.MaxProjects.ValueInt64(),
+		MaxStorageGB:  plan.MaxStorageGB.ValueFloat64(),
+		MaxAIRequests: plan.MaxAIRequests.ValueInt64(),
+	}, &quotas)
+	if err == nil {
+		plan.fill(&quotas)
+	}
+	return err
+}
+
+func (r *quotasResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan quotasModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply quotas", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *quotasResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state quotasModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var quotas Quotas
+	err := r.client.Get(ctx, orgPath(state.Organization.ValueString(), "quotas"), &quotas)
+	if errors.Is(err, errNotFound) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read quotas", err.Error())
+		return
+	}
+	state.fill(&quotas)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *quotasResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan quotasModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply quotas", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete only forgets the quotas; every organization always has limits
+func (r *quotasResource) Delete(context.Context, resource.DeleteRequest, *resource.DeleteResponse) {}
+
+func (r *quotasResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("organization"), req, resp)
+}
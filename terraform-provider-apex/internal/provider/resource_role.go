@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.ResourceWithConfigure   = (*roleResource)(nil)
+	_ resource.ResourceWithImportState = (*roleResource)(nil)
+)
+
+type roleResource struct {
+	client *Client
+}
+
+type roleModel struct {
+	Organization types.String `tfsdk:"organization"`
+	Name         types.String `tfsdk:"name"`
+	ID           types.Int64  `tfsdk:"id"`
+	Description  types.String `tfsdk:"description"`
+	Permissions  types.Set    `tfsdk:"permissions"`
+	IsDefault    types.Bool   `tfsdk:"is_default"`
+}
+
+func newRoleResource() resource.Resource {
+	return &roleResource{}
+}
+
+func (r *roleResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_role"
+}
+
+func (r *roleResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	resp.Schema = schema.Schema{
+		Description: "A custom organization role. The Owner role is managed by the platform.",
+		Attributes: map[string]schema.Attribute{
+			"organization": schema.StringAttribute{Description: "Organization slug.", Required: true, PlanModifiers: replace},
+			"name":         schema.StringAttribute{Description: "Role name; its stable external ID.", Required: true, PlanModifiers: replace},
+			"id": schema.Int64Attribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()},
+			},
+			"description": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("")},
+			"permissions": schema.SetAttribute{
+				Description: `Lowercase "resource:action" pairs. Resources: organization, roles, projects, audit_logs, billing. Actions: read, create, update, delete, manage.`,
+				ElementType: types.StringType,
+				Required:    true,
+			},
+			"is_default": schema.BoolAttribute{
+				Description: "Whether new members get this role by default.",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+			},
+		},
+	}
+}
+
+func (r *roleResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = clientFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (m *roleModel) path() string {
+	return orgPath(m.Organization.ValueString(), "roles", m.Name.ValueString())
+}
+
+func (m *roleModel) fill(ctx context.Context, role *Role) {
+	m.ID = types.Int64Value(role.ID)
+	m.Description = types.StringValue(role.Description)
+	m.Permissions, _ = types.SetValueFrom(ctx, types.StringType, role.Permissions)
+	m.IsDefault = types.BoolValue(role.IsDefault)
+}
+
+func (r *roleResource) apply(ctx context.Context, plan *roleModel) error {
+	spec := Role{Description: plan.Description.ValueString(), IsDefault: plan.IsDefault.ValueBool()}
+	if diags := plan.Permissions.ElementsAs(ctx, &spec.Permissions, false); diags.HasError() {
+		return errors.New("permissions must be a set of strings")
+	}
+	var role Role
+	err := r.client.Put(ctx, plan.path(), spec, &role)
+	if err == nil {
+		plan.fill(ctx, &role)
+	}
+	return err
+}
+
+func (r *roleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan roleModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply role", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *roleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state roleModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var role Role
+	err := r.client.Get(ctx, state.path(), &role)
+	if errors.Is(err, errNotFound) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read role", err.Error())
+		return
+	}
+	state.fill(ctx, &role)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *roleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan roleModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply role", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *roleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state roleModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.Delete(ctx, state.path()); err != nil {
+		resp.Diagnostics.AddError("Failed to delete role", err.Error())
+	}
+}
+
+// ImportState accepts "<organization>/<role name>"
+func (r *roleResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	org, name, ok := strings.Cut(req.ID, "/")
+	if !ok || org == "" || name == "" {
+		resp.Diagnostics.AddError("Invalid import ID", "Expected <organization>/<role name>.")
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("organization"), org)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
+}
@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.ResourceWithConfigure   = (*webhookResource)(nil)
+	_ resource.ResourceWithImportState = (*webhookResource)(nil)
+)
+
+type webhookResource struct {
+	client *Client
+}
+
+type webhookModel struct {
+	Organization types.String `tfsdk:"organization"`
+	WebhookID    types.String `tfsdk:"webhook_id"`
+	URL          types.String `tfsdk:"url"`
+	Events       types.Set    `tfsdk:"events"`
+	Secret       types.String `tfsdk:"secret"`
+	Enabled      types.Bool   `tfsdk:"enabled"`
+}
+
+func newWebhookResource() resource.Resource {
+	return &webhookResource{}
+}
+
+func (r *webhookResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_webhook"
+}
+
+func (r *webhookResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	resp.Schema = schema.Schema{
+		Description: "Delivers an organization's audit events to an HTTPS endpoint, signed with X-Apex-Signature.",
+		Attributes: map[string]schema.Attribute{
+			"organization": schema.StringAttribute{Description: "Organization slug.", Required: true, PlanModifiers: replace},
+			"webhook_id": schema.StringAttribute{
+				Description:   "Stable external ID: 1-64 lowercase letters, digits, dots, underscores or hyphens.",
+				Required:      true,
+				PlanModifiers: replace,
+			},
+			"url": schema.StringAttribute{Description: "HTTPS endpoint.", Required: true},
+			"events": schema.SetAttribute{
+				Description:   `Audit actions to deliver, e.g. member_added. Defaults to ["*"] (everything).`,
+				ElementType:   types.StringType,
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Set{setplanmodifier.UseStateForUnknown()},
+			},
+			"secret": schema.StringAttribute{
+				Description: "HMAC signing secret, 16-200 characters. The API never returns it.",
+				Required:    true,
+				Sensitive:   true,
+			},
+			"enabled": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(true)},
+		},
+	}
+}
+
+func (r *webhookResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = clientFrom(req.ProviderData, &resp.Diagnostics)
+}
+
+func (m *webhookModel) path() string {
+	return orgPath(m.Organization.ValueString(), "webhooks", m.WebhookID.ValueString())
+}
+
+// fill copies the API's view into the model. The secret is write-only and
+// keeps its configured value.
+func (m *webhookModel) fill(ctx context.Context, hook *Webhook) {
+	m.URL = types.StringValue(hook.URL)
+	m.Events, _ = types.SetValueFrom(ctx, types.StringType, hook.Events)
+	if hook.Enabled != nil {
+		m.Enabled = types.BoolValue(*hook.Enabled)
+	}
+}
+
+func (r *webhookResource) apply(ctx context.Context, plan *webhookModel) error {
+	enabled := plan.Enabled.ValueBool()
+	spec := Webhook{URL: plan.URL.ValueString(), Secret: plan.Secret.ValueString(), Enabled: &enabled}
+	if !plan.Events.IsUnknown() && !plan.Events.IsNull() {
+		if diags := plan.Events.ElementsAs(ctx, &spec.Events, false); diags.HasError() {
+			return errors.New("events must be a set of strings")
+		}
+	}
+	var hook Webhook
+	err := r.client.Put(ctx, plan.path(), spec, &hook)
+	if err == nil {
+		plan.fill(ctx, &hook)
+	}
+	return err
+}
+
+func (r *webhookResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan webhookModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply webhook", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *webhookResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state webhookModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var hook Webhook
+	err := r.client.Get(ctx, state.path(), &hook)
+	if errors.Is(err, errNotFound) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read webhook", err.Error())
+		return
+	}
+	state.fill(ctx, &hook)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *webhookResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan webhookModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.apply(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply webhook", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *webhookResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state webhookModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.Delete(ctx, state.path()); err != nil {
+		resp.Diagnostics.AddError("Failed to delete webhook", err.Error())
+	}
+}
+
+// ImportState accepts "<organization>/<webhook id>". The secret is not
+// readable, so set it in configuration; the next apply rotates it to match.
+func (r *webhookResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	org, id, ok := strings.Cut(req.ID, "/")
+	if !ok || org == "" || id == "" {
+		resp.Diagnostics.AddError("Invalid import ID", "Expected <organization>/<webhook id>.")
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("organization"), org)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("webhook_id"), id)...)
+}
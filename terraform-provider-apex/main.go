@@ -0,0 +1,30 @@
+// Command terraform-provider-apex is the Terraform provider for APEX.BUILD
+// organizations, built on the backend's management API (/api/v1/manage).
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+
+	"github.com/spencerandtheteagues/apex-build-platform/terraform-provider-apex/internal/provider"
+)
+
+// version is set by the release build
+var version = "dev"
+
+func main() {
+	var debug bool
+	flag.BoolVar(&debug, "debug", false, "run the provider with support for debuggers like delve")
+	flag.Parse()
+
+	err := providerserver.Serve(context.Background(), provider.New(version), providerserver.ServeOpts{
+		Address: "registry.terraform.io/apex-build/apex",
+		Debug:   debug,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}